@@ -0,0 +1,178 @@
+package agent
+
+import (
+	"strings"
+	"time"
+
+	"printmaster/agent/scanner"
+	"printmaster/agent/supplies"
+)
+
+// PrinterInfoFromIPP builds a PrinterInfo from printer attributes read over
+// IPP, for printers that do not answer SNMP. Without a serial the IP is used
+// as a stand-in, which device reconciliation replaces once the real serial
+// is read.
+func PrinterInfoFromIPP(res *scanner.IPPResult, meta *ScanMeta) PrinterInfo {
+	pi := PrinterInfo{IP: res.IP, LastSeen: time.Now()}
+	if meta != nil {
+		pi.MAC = meta.MAC
+		pi.OpenPorts = meta.OpenPorts
+		pi.DiscoveryMethods = append(pi.DiscoveryMethods, meta.DiscoveryMethods...)
+		pi.Hostname = meta.Hostname
+	}
+	MergeIPP(&pi, res)
+	if pi.Serial == "" {
+		pi.Serial = pi.IP
+	}
+	pi.DetectionReasons = append(pi.DetectionReasons, "ipp: printer attributes on port 631")
+	return pi
+}
+
+// MergeIPP fills fields SNMP left empty from an IPP result. Values already
+// set are kept.
+func MergeIPP(pi *PrinterInfo, res *scanner.IPPResult) {
+	if pi == nil || res == nil {
+		return
+	}
+	if pi.IP == "" {
+		pi.IP = res.IP
+	}
+	setIfEmpty(&pi.Manufacturer, res.Manufacturer)
+	setIfEmpty(&pi.Model, res.Model)
+	setIfEmpty(&pi.Serial, res.Serial)
+	setIfEmpty(&pi.Location, res.Location)
+	setIfEmpty(&pi.Description, res.Info)
+	setIfEmpty(&pi.Firmware, res.Firmware)
+	setIfEmpty(&pi.WebUIURL, res.WebUIURL)
+	if res.Duplex {
+		pi.DuplexSupported = true
+		pi.HasDuplex = true
+	}
+	if !pi.IsColor && !pi.IsMono {
+		pi.IsColor = res.Color
+		pi.IsMono = !res.Color
+	}
+	if pi.DeviceType == "" {
+		pi.DeviceType = "Mono Printer"
+		if pi.IsColor {
+			pi.DeviceType = "Color Printer"
+		}
+	}
+	if !containsString(pi.AdvertisedServices, "ipp") {
+		pi.AdvertisedServices = append(pi.AdvertisedServices, "ipp")
+	}
+	if !containsString(pi.DiscoveryMethods, "ipp") {
+		pi.DiscoveryMethods = append(pi.DiscoveryMethods, "ipp")
+	}
+	if len(pi.StatusMessages) == 0 {
+		if res.StateMessage != "" {
+			pi.StatusMessages = append(pi.StatusMessages, res.StateMessage)
+		}
+		pi.StatusMessages = append(pi.StatusMessages, res.StateReasons...)
+	}
+
+	if pi.PageCount == 0 && res.Impressions > 0 {
+		pi.PageCount = res.Impressions
+		if pi.Meters == nil {
+			pi.Meters = map[string]int{}
+		}
+		pi.Meters["total_pages"] = res.Impressions
+		if res.MonoImpressions > 0 {
+			pi.MonoImpressions = res.MonoImpressions
+			pi.Meters["mono_pages"] = res.MonoImpressions
+		}
+		if res.ColorImpressions > 0 {
+			pi.ColorImpressions = res.ColorImpressions
+			pi.Meters["color_pages"] = res.ColorImpressions
+		}
+	}
+
+	if len(pi.TonerLevels) == 0 && len(res.Supplies) > 0 {
+		mergeIPPSupplies(pi, res.Supplies)
+	}
+}
+
+// mergeIPPSupplies records supply levels under the same normalized keys the
+// SNMP parser uses (toner_black, ...).
+func mergeIPPSupplies(pi *PrinterInfo, list []scanner.IPPSupply) {
+	pi.TonerLevels = map[string]int{}
+	pi.SupplyReadings = map[string]supplies.Reading{}
+	for _, s := range list {
+		desc := s.Name
+		key := supplies.NormalizeDescription(desc)
+		if key == "" {
+			key = supplies.NormalizeDescription(strings.TrimSpace(ippColorName(s.Color) + " " + s.Type))
+		}
+		if key == "" {
+			key = desc
+		}
+		if key == "" {
+			continue
+		}
+		unit := supplies.UnitPercent
+		if s.Max != 100 {
+			unit = supplies.UnitNotReported
+		}
+		reading := supplies.Normalize(s.Level, s.Max, unit)
+		pi.SupplyReadings[key] = reading
+		if reading.HasPercent() {
+			pi.TonerLevels[key] = int(reading.Percent + 0.5)
+		}
+		if desc != "" && !containsString(pi.Consumables, desc) {
+			pi.Consumables = append(pi.Consumables, desc)
+		}
+		level, hasLevel := pi.TonerLevels[key]
+		switch key {
+		case "toner_black":
+			pi.TonerDescBlack = desc
+			if hasLevel {
+				pi.TonerLevelBlack = level
+			}
+		case "toner_cyan":
+			pi.TonerDescCyan = desc
+			if hasLevel {
+				pi.TonerLevelCyan = level
+			}
+		case "toner_magenta":
+			pi.TonerDescMagenta = desc
+			if hasLevel {
+				pi.TonerLevelMagenta = level
+			}
+		case "toner_yellow":
+			pi.TonerDescYellow = desc
+			if hasLevel {
+				pi.TonerLevelYellow = level
+			}
+		}
+	}
+}
+
+// ippColorName maps marker-colors values (#RRGGBB) to colorant names.
+func ippColorName(c string) string {
+	switch strings.ToUpper(strings.TrimSpace(c)) {
+	case "#000000":
+		return "black"
+	case "#00FFFF":
+		return "cyan"
+	case "#FF00FF":
+		return "magenta"
+	case "#FFFF00":
+		return "yellow"
+	}
+	return c
+}
+
+func setIfEmpty(dst *string, v string) {
+	if *dst == "" && v != "" {
+		*dst = v
+	}
+}
+
+func containsString(list []string, v string) bool {
+	for _, s := range list {
+		if s == v {
+			return true
+		}
+	}
+	return false
+}
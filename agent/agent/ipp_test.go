@@ -0,0 +1,54 @@
+package agent
+
+import (
+	"testing"
+
+	"printmaster/agent/scanner"
+)
+
+func TestPrinterInfoFromIPP(t *testing.T) {
+	res := &scanner.IPPResult{
+		IP:               "192.0.2.10",
+		MakeAndModel:     "HP Color LaserJet Pro M454dw",
+		Manufacturer:     "HP",
+		Model:            "Color LaserJet Pro M454dw",
+		Location:         "2nd floor",
+		Color:            true,
+		Duplex:           true,
+		Impressions:      12345,
+		MonoImpressions:  10000,
+		ColorImpressions: 2345,
+		Supplies: []scanner.IPPSupply{
+			{Name: "Black Cartridge", Color: "#000000", Type: "toner", Level: 80, Max: 100},
+			{Name: "Cyan Cartridge", Color: "#00FFFF", Type: "toner", Level: -3, Max: 100},
+		},
+	}
+	pi := PrinterInfoFromIPP(res, &ScanMeta{OpenPorts: []int{631}})
+	if pi.Serial != "192.0.2.10" {
+		t.Errorf("expected the IP as stand-in serial, got %q", pi.Serial)
+	}
+	if pi.Manufacturer != "HP" || pi.Model != "Color LaserJet Pro M454dw" || !pi.IsColor || !pi.HasDuplex || pi.DeviceType != "Color Printer" {
+		t.Errorf("identity: %+v", pi)
+	}
+	if pi.PageCount != 12345 || pi.Meters["mono_pages"] != 10000 || pi.Meters["color_pages"] != 2345 {
+		t.Errorf("counters: page=%d meters=%v", pi.PageCount, pi.Meters)
+	}
+	if pi.TonerLevelBlack != 80 || pi.TonerLevels["toner_black"] != 80 {
+		t.Errorf("black toner: %d %v", pi.TonerLevelBlack, pi.TonerLevels)
+	}
+	if lvl, ok := pi.TonerLevels["toner_cyan"]; !ok || lvl != 10 {
+		t.Errorf("some-remaining cyan should be estimated low, got %v", pi.TonerLevels)
+	}
+}
+
+func TestMergeIPPKeepsSNMPValues(t *testing.T) {
+	pi := PrinterInfo{IP: "192.0.2.11", Serial: "CNB1234", Model: "M404n", PageCount: 500, TonerLevels: map[string]int{"toner_black": 30}}
+	MergeIPP(&pi, &scanner.IPPResult{Model: "LaserJet M404n", Serial: "OTHER", Impressions: 900, Location: "Lobby",
+		Supplies: []scanner.IPPSupply{{Name: "Black", Level: 90, Max: 100}}})
+	if pi.Serial != "CNB1234" || pi.Model != "M404n" || pi.PageCount != 500 || pi.TonerLevels["toner_black"] != 30 {
+		t.Errorf("SNMP values overwritten: %+v", pi)
+	}
+	if pi.Location != "Lobby" {
+		t.Errorf("missing field not filled: %q", pi.Location)
+	}
+}
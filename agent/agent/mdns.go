@@ -48,3 +48,60 @@ func StartMDNSBrowser(ctx context.Context, enqueue func(string) bool) {
 		}()
 	}
 }
+
+// MDNSAgentService is the DNS-SD service type agents advertise their web UI as.
+const MDNSAgentService = "_printmaster-agent._tcp"
+
+// MDNSAdvertisement describes the agent web UI published by
+// StartMDNSAdvertiser. A port of 0 means that listener is disabled.
+type MDNSAdvertisement struct {
+	Instance  string // DNS-SD instance name, usually the hostname
+	AgentID   string
+	Version   string
+	HTTPPort  int
+	HTTPSPort int
+}
+
+// Port returns the port the service record points at, preferring HTTPS.
+func (a MDNSAdvertisement) Port() int {
+	if a.HTTPSPort > 0 {
+		return a.HTTPSPort
+	}
+	return a.HTTPPort
+}
+
+// TXT returns the TXT records published with the service.
+func (a MDNSAdvertisement) TXT() []string {
+	txt := []string{"path=/"}
+	if a.AgentID != "" {
+		txt = append(txt, "agent_id="+a.AgentID)
+	}
+	if a.Version != "" {
+		txt = append(txt, "version="+a.Version)
+	}
+	if a.HTTPSPort > 0 {
+		txt = append(txt, fmt.Sprintf("https_port=%d", a.HTTPSPort))
+	}
+	if a.HTTPPort > 0 {
+		txt = append(txt, fmt.Sprintf("http_port=%d", a.HTTPPort))
+	}
+	return txt
+}
+
+// StartMDNSAdvertiser publishes the agent web UI as MDNSAgentService on all
+// multicast-capable interfaces until the context is canceled.
+func StartMDNSAdvertiser(ctx context.Context, ad MDNSAdvertisement) error {
+	if ad.Port() <= 0 {
+		return fmt.Errorf("mDNS advertise: no web UI port")
+	}
+	server, err := zeroconf.Register(ad.Instance, MDNSAgentService, "local.", ad.Port(), ad.TXT(), nil)
+	if err != nil {
+		return fmt.Errorf("mDNS advertise: %w", err)
+	}
+	Info(fmt.Sprintf("mDNS advertising %s as %q on port %d", MDNSAgentService, ad.Instance, ad.Port()))
+	go func() {
+		<-ctx.Done()
+		server.Shutdown()
+	}()
+	return nil
+}
@@ -1,6 +1,10 @@
 package agent
 
-import "time"
+import (
+	"time"
+
+	"printmaster/agent/supplies"
+)
 
 // MergePrinterInfo merges two PrinterInfo values, preferring non-empty and
 // more complete fields from `extra` while preserving `base` as the fallback.
@@ -64,6 +68,14 @@ func MergePrinterInfo(base PrinterInfo, extra PrinterInfo) PrinterInfo {
 		}
 	}
 
+	// supply readings follow the toner levels they back
+	for k, r := range extra.SupplyReadings {
+		if base.SupplyReadings == nil {
+			base.SupplyReadings = map[string]supplies.Reading{}
+		}
+		base.SupplyReadings[k] = r
+	}
+
 	// consumables: union
 	seen := map[string]bool{}
 	newConsum := []string{}
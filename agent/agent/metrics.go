@@ -6,6 +6,8 @@ import (
 	"path/filepath"
 	"sync"
 	"time"
+
+	"printmaster/agent/supplies"
 )
 
 // Metrics holds simple counters to help diagnose scanning behavior.
@@ -105,6 +107,9 @@ type DeviceMetricsSnapshot struct {
 	MonoPages   int                    `json:"mono_pages"`
 	ScanCount   int                    `json:"scan_count"`
 	TonerLevels map[string]interface{} `json:"toner_levels"`
+	// SupplyReadings holds the raw level, capacity and unit behind each
+	// percentage, keyed by normalized supply name (toner_black, drum_life, ...)
+	SupplyReadings map[string]supplies.Reading `json:"supply_readings,omitempty"`
 
 	// Additional detailed impression counters (HP-specific and others)
 	FaxPages          int `json:"fax_pages,omitempty"`
@@ -118,6 +123,8 @@ type DeviceMetricsSnapshot struct {
 	ScanToHostFlatbed int `json:"scan_to_host_flatbed,omitempty"`
 	ScanToHostADF     int `json:"scan_to_host_adf,omitempty"`
 	DuplexSheets      int `json:"duplex_sheets,omitempty"`
+	SimplexSheets     int `json:"simplex_sheets,omitempty"`
+	NUpSheets         int `json:"nup_sheets,omitempty"`
 	JamEvents         int `json:"jam_events,omitempty"`
 	ScannerJamEvents  int `json:"scanner_jam_events,omitempty"`
 }
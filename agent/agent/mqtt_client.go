@@ -0,0 +1,226 @@
+package agent
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+)
+
+// Per-agent MQTT topics, published under <prefix>/agents/<agent_id>/
+const (
+	MQTTTopicHeartbeat  = "heartbeat"
+	MQTTTopicDevices    = "devices"
+	MQTTTopicMetrics    = "metrics"
+	MQTTTopicMeterReads = "meter_reads"
+	MQTTTopicStatus     = "status"
+	MQTTTopicCommands   = "commands"
+)
+
+// ErrMQTTNotConnected is returned by publishes while the broker connection
+// is down. The client keeps reconnecting in the background.
+var ErrMQTTNotConnected = errors.New("mqtt broker not connected")
+
+// MQTTOptions configures the broker connection.
+type MQTTOptions struct {
+	Broker      string // tcp://, ssl://, ws:// or wss:// URL
+	ClientID    string // defaults to printmaster-agent-<agent id>
+	Username    string
+	Password    string
+	TopicPrefix string // defaults to "printmaster"
+	AgentID     string
+	QoS         byte
+	// CleanSession discards the broker-side session on connect. Leave it off
+	// so subscriptions and queued commands survive reconnects.
+	CleanSession bool
+	KeepAlive    time.Duration
+	TLSConfig    *tls.Config
+	// StoreDir keeps in-flight messages on disk so they are redelivered after
+	// a restart (in memory when empty)
+	StoreDir string
+}
+
+// MQTTClient publishes agent data to per-agent topics on an MQTT broker and
+// delivers commands from the agent's command topic to the registered
+// CommandHandler.
+type MQTTClient struct {
+	opts   MQTTOptions
+	client mqtt.Client
+
+	mu        sync.RWMutex
+	connected bool
+}
+
+// NewMQTTClient validates the options and prepares a client. Call Start to
+// connect.
+func NewMQTTClient(opts MQTTOptions) (*MQTTClient, error) {
+	opts.Broker = strings.TrimSpace(opts.Broker)
+	if opts.Broker == "" {
+		return nil, fmt.Errorf("mqtt broker not configured")
+	}
+	if strings.TrimSpace(opts.AgentID) == "" {
+		return nil, fmt.Errorf("mqtt transport requires an agent ID")
+	}
+	if opts.QoS > 2 {
+		return nil, fmt.Errorf("invalid mqtt qos %d", opts.QoS)
+	}
+	opts.TopicPrefix = strings.Trim(strings.TrimSpace(opts.TopicPrefix), "/")
+	if opts.TopicPrefix == "" {
+		opts.TopicPrefix = "printmaster"
+	}
+	if opts.ClientID == "" {
+		opts.ClientID = "printmaster-agent-" + opts.AgentID
+	}
+	if opts.KeepAlive <= 0 {
+		opts.KeepAlive = 60 * time.Second
+	}
+
+	c := &MQTTClient{opts: opts}
+	co := mqtt.NewClientOptions().
+		AddBroker(opts.Broker).
+		SetClientID(opts.ClientID).
+		SetUsername(opts.Username).
+		SetPassword(opts.Password).
+		SetCleanSession(opts.CleanSession).
+		SetResumeSubs(!opts.CleanSession).
+		SetKeepAlive(opts.KeepAlive).
+		SetAutoReconnect(true).
+		SetConnectRetry(true).
+		SetConnectRetryInterval(5*time.Second).
+		SetMaxReconnectInterval(2*time.Minute).
+		SetOrderMatters(false).
+		SetWill(c.Topic(MQTTTopicStatus), "offline", opts.QoS, true).
+		SetOnConnectHandler(c.onConnect).
+		SetConnectionLostHandler(c.onConnectionLost)
+	if opts.TLSConfig != nil {
+		co.SetTLSConfig(opts.TLSConfig)
+	}
+	if opts.StoreDir != "" {
+		co.SetStore(mqtt.NewFileStore(opts.StoreDir))
+	}
+	c.client = mqtt.NewClient(co)
+	return c, nil
+}
+
+// Topic returns the full topic for one of the MQTTTopic* kinds.
+func (c *MQTTClient) Topic(kind string) string {
+	return c.opts.TopicPrefix + "/agents/" + c.opts.AgentID + "/" + kind
+}
+
+// Start connects in the background; the client keeps retrying until the
+// broker is reachable.
+func (c *MQTTClient) Start() error {
+	c.client.Connect()
+	InfoCtx("MQTT client started", "broker", c.opts.Broker, "client_id", c.opts.ClientID, "clean_session", c.opts.CleanSession)
+	return nil
+}
+
+// Stop marks the agent offline and disconnects.
+func (c *MQTTClient) Stop() {
+	if c.IsConnected() {
+		token := c.client.Publish(c.Topic(MQTTTopicStatus), c.opts.QoS, true, "offline")
+		token.WaitTimeout(2 * time.Second)
+	}
+	c.client.Disconnect(250)
+	c.setConnected(false)
+}
+
+// IsConnected reports whether the broker connection is up.
+func (c *MQTTClient) IsConnected() bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.connected
+}
+
+// Broker returns the configured broker URL.
+func (c *MQTTClient) Broker() string {
+	return c.opts.Broker
+}
+
+// Publish sends payload as JSON to the given topic kind and waits for the
+// broker to acknowledge it (at QoS 1 and 2).
+func (c *MQTTClient) Publish(ctx context.Context, kind string, payload interface{}) error {
+	if !c.IsConnected() {
+		return ErrMQTTNotConnected
+	}
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("failed to encode %s message: %w", kind, err)
+	}
+	token := c.client.Publish(c.Topic(kind), c.opts.QoS, false, data)
+	select {
+	case <-token.Done():
+		if err := token.Error(); err != nil {
+			return fmt.Errorf("mqtt publish to %s failed: %w", kind, err)
+		}
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// PublishBatch publishes items in the same envelope the HTTP batch
+// endpoints accept: {"agent_id", "timestamp", <field>: items}.
+func (c *MQTTClient) PublishBatch(ctx context.Context, kind, field string, items []interface{}) error {
+	return c.Publish(ctx, kind, map[string]interface{}{
+		"agent_id":  c.opts.AgentID,
+		"timestamp": time.Now().UTC(),
+		field:       items,
+	})
+}
+
+func (c *MQTTClient) setConnected(connected bool) {
+	c.mu.Lock()
+	c.connected = connected
+	c.mu.Unlock()
+}
+
+func (c *MQTTClient) onConnect(client mqtt.Client) {
+	c.setConnected(true)
+	InfoCtx("Connected to MQTT broker", "broker", c.opts.Broker)
+	client.Publish(c.Topic(MQTTTopicStatus), c.opts.QoS, true, "online")
+	topic := c.Topic(MQTTTopicCommands)
+	token := client.Subscribe(topic, c.opts.QoS, func(_ mqtt.Client, msg mqtt.Message) {
+		c.handleCommandMessage(msg.Payload())
+	})
+	go func() {
+		if token.WaitTimeout(10*time.Second) && token.Error() != nil {
+			WarnCtx("Failed to subscribe to MQTT command topic", "topic", topic, "error", token.Error())
+		}
+	}()
+}
+
+func (c *MQTTClient) onConnectionLost(_ mqtt.Client, err error) {
+	c.setConnected(false)
+	WarnCtx("MQTT broker connection lost", "broker", c.opts.Broker, "error", err)
+}
+
+// handleCommandMessage decodes a command published to the command topic.
+// The payload matches the data of a WebSocket command message.
+func (c *MQTTClient) handleCommandMessage(payload []byte) {
+	command, data, err := parseMQTTCommand(payload)
+	if err != nil {
+		WarnCtx("Ignoring invalid MQTT command", "error", err)
+		return
+	}
+	InfoCtx("Received command over MQTT", "command", command)
+	dispatchCommand(command, data)
+}
+
+func parseMQTTCommand(payload []byte) (string, map[string]interface{}, error) {
+	var data map[string]interface{}
+	if err := json.Unmarshal(payload, &data); err != nil {
+		return "", nil, err
+	}
+	command, _ := data["command"].(string)
+	if command == "" {
+		return "", nil, fmt.Errorf("no command field")
+	}
+	return command, data, nil
+}
@@ -0,0 +1,54 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestNewMQTTClientDefaultsAndTopics(t *testing.T) {
+	t.Parallel()
+
+	if _, err := NewMQTTClient(MQTTOptions{AgentID: "a1"}); err == nil {
+		t.Fatal("expected missing broker to be rejected")
+	}
+	if _, err := NewMQTTClient(MQTTOptions{Broker: "tcp://broker:1883"}); err == nil {
+		t.Fatal("expected missing agent ID to be rejected")
+	}
+	if _, err := NewMQTTClient(MQTTOptions{Broker: "tcp://broker:1883", AgentID: "a1", QoS: 3}); err == nil {
+		t.Fatal("expected invalid qos to be rejected")
+	}
+
+	c, err := NewMQTTClient(MQTTOptions{Broker: " tcp://broker:1883 ", AgentID: "a1", TopicPrefix: "/fleet/"})
+	if err != nil {
+		t.Fatalf("NewMQTTClient: %v", err)
+	}
+	if got := c.Topic(MQTTTopicDevices); got != "fleet/agents/a1/devices" {
+		t.Fatalf("unexpected topic %q", got)
+	}
+	if c.opts.ClientID != "printmaster-agent-a1" || c.Broker() != "tcp://broker:1883" {
+		t.Fatalf("unexpected defaults: %+v", c.opts)
+	}
+
+	c, _ = NewMQTTClient(MQTTOptions{Broker: "tcp://broker:1883", AgentID: "a1"})
+	if got := c.Topic(MQTTTopicCommands); got != "printmaster/agents/a1/commands" {
+		t.Fatalf("unexpected default topic %q", got)
+	}
+	if err := c.Publish(context.Background(), MQTTTopicHeartbeat, map[string]string{}); !errors.Is(err, ErrMQTTNotConnected) {
+		t.Fatalf("expected not connected error, got %v", err)
+	}
+}
+
+func TestParseMQTTCommand(t *testing.T) {
+	t.Parallel()
+
+	command, data, err := parseMQTTCommand([]byte(`{"command":"sync_settings","reason":"policy"}`))
+	if err != nil || command != "sync_settings" || data["reason"] != "policy" {
+		t.Fatalf("unexpected result %q %v %v", command, data, err)
+	}
+	for _, payload := range []string{`not json`, `{"reason":"x"}`, `{"command":""}`} {
+		if _, _, err := parseMQTTCommand([]byte(payload)); err == nil {
+			t.Fatalf("expected %s to be rejected", payload)
+		}
+	}
+}
@@ -0,0 +1,28 @@
+package agent
+
+import (
+	"net/http"
+	"net/url"
+	"sync/atomic"
+
+	"printmaster/agent/netproxy"
+)
+
+var outboundProxy atomic.Pointer[netproxy.Resolver]
+
+// SetOutboundProxy sets the proxy used for traffic to the PrintMaster server.
+// Clients pick up the change on their next request; nil connects direct.
+func SetOutboundProxy(r *netproxy.Resolver) {
+	outboundProxy.Store(r)
+}
+
+// OutboundProxy returns the proxy resolver for server traffic (nil when direct).
+func OutboundProxy() *netproxy.Resolver {
+	return outboundProxy.Load()
+}
+
+// ProxyForServer is an http.Transport.Proxy function for requests to the
+// server. Device traffic must not use it.
+func ProxyForServer(req *http.Request) (*url.URL, error) {
+	return OutboundProxy().ProxyFor(req.Context(), req.URL)
+}
@@ -2,7 +2,6 @@ package agent
 
 import (
 	"context"
-	"crypto/tls"
 	"fmt"
 	"net/http"
 	"net/url"
@@ -13,6 +12,7 @@ import (
 	"strings"
 	"time"
 
+	"printmaster/agent/devicetls"
 	"printmaster/agent/scanner"
 	"printmaster/agent/scanner/vendor"
 	"printmaster/agent/supplies"
@@ -187,9 +187,29 @@ func normalizeNetworkValue(value, fieldType string) string {
 	}
 }
 
+// defaultSNMPCommunities are community strings shipped as factory defaults.
+var defaultSNMPCommunities = map[string]bool{"public": true, "private": true, "internal": true}
+
+// snmpAccessInfo reports the SNMP version used for scanning and whether the
+// configured community is a well-known default. A device that returned data
+// evidently accepted these credentials.
+func snmpAccessInfo() (version string, defaultCommunity bool) {
+	cfg, err := scanner.GetSNMPConfig()
+	if err != nil {
+		return "", false
+	}
+	version = cfg.Version.String()
+	if cfg.Version != gosnmp.Version3 {
+		defaultCommunity = defaultSNMPCommunities[strings.ToLower(cfg.Community)]
+	}
+	return version, defaultCommunity
+}
+
 // detectWebUIURL attempts to build an HTTP or HTTPS URL for the device's web interface.
 // It checks open ports (from meta), probes the web service, and follows redirects.
-func detectWebUIURL(ip string, meta *ScanMeta, pduByOid map[string]gosnmp.SnmpPDU) string {
+// The fingerprint of the responding web server is returned alongside the URL (nil
+// when no candidate answered).
+func detectWebUIURL(ip string, meta *ScanMeta, pduByOid map[string]gosnmp.SnmpPDU) (string, *WebFingerprint) {
 	// Check if HTTP (80) or HTTPS (443) ports are open from port scan
 	hasHTTP := false
 	hasHTTPS := false
@@ -226,47 +246,51 @@ func detectWebUIURL(ip string, meta *ScanMeta, pduByOid map[string]gosnmp.SnmpPD
 		}
 	}
 
+	// Devices behind forwarded ports serve their web UI on the overridden port
+	host := scanner.HTTPHost(ip)
+
 	// Try HTTPS first (prefer secure), then HTTP
 	// We'll probe each to verify it's actually accessible and follow redirects
 	var candidates []string
 	if hasHTTPS {
-		candidates = append(candidates, fmt.Sprintf("https://%s", ip))
+		candidates = append(candidates, fmt.Sprintf("https://%s", host))
 	}
 	if hasHTTP {
-		candidates = append(candidates, fmt.Sprintf("http://%s", ip))
+		candidates = append(candidates, fmt.Sprintf("http://%s", host))
 	}
 
 	// If no ports detected, still try both HTTPS and HTTP as fallback
 	if len(candidates) == 0 {
 		candidates = []string{
-			fmt.Sprintf("https://%s", ip),
-			fmt.Sprintf("http://%s", ip),
+			fmt.Sprintf("https://%s", host),
+			fmt.Sprintf("http://%s", host),
 		}
 	}
 
 	// Probe each candidate URL to verify it's accessible
 	for _, url := range candidates {
-		if finalURL := probeWebUI(url); finalURL != "" {
-			return finalURL
+		if finalURL, fp := probeWebUI(url); finalURL != "" {
+			return finalURL, fp
 		}
 	}
 
 	// If nothing works, default to http://<ip>
-	return fmt.Sprintf("http://%s", ip)
+	return fmt.Sprintf("http://%s", host), nil
 }
 
 // probeWebUI checks if a URL is accessible and follows redirects to get the final URL.
-// Returns the final URL if accessible, empty string otherwise.
-func probeWebUI(probeURL string) string {
+// Returns the final URL and the server's fingerprint (banner headers, page title and
+// TLS certificate) if accessible, empty string otherwise.
+func probeWebUI(probeURL string) (string, *WebFingerprint) {
 	// Validate and parse the initial URL to prevent SSRF
 	parsedInitial, err := url.Parse(probeURL)
 	if err != nil {
-		return ""
+		return "", nil
 	}
 
 	// Only allow http and https schemes
 	if parsedInitial.Scheme != "http" && parsedInitial.Scheme != "https" {
-		return ""
+		return "", nil
 	}
 
 	// Reconstruct URL from validated components to break CodeQL taint chain.
@@ -294,43 +318,38 @@ func probeWebUI(probeURL string) string {
 			}
 			return nil
 		},
-		Transport: &http.Transport{
-			TLSClientConfig: &tls.Config{
-				// #nosec G402 -- InsecureSkipVerify intentionally enabled:
-				// Network printers commonly use self-signed SSL certificates that
-				// would fail standard validation. This is a local network probe to
-				// detect printer web UIs, not a general-purpose HTTP client.
-				// SSRF protections are implemented above (redirect host validation).
-				InsecureSkipVerify: true,
-			},
+		// Certificates are not verified: printers use self-signed ones.
+		// devicetls applies any per-device TLS override for the probed IP.
+		// SSRF protections are implemented above (redirect host validation).
+		Transport: devicetls.Transport(&http.Transport{
 			DisableKeepAlives: true,
-		},
+		}),
 	}
 
 	req, err := http.NewRequest("GET", validatedURL, nil)
 	if err != nil {
-		return ""
+		return "", nil
 	}
 
 	resp, err := client.Do(req)
 	if err != nil {
 		// Connection failed, URL not accessible
-		return ""
+		return "", nil
 	}
 	defer resp.Body.Close()
 
 	// If we got a successful response (2xx, 3xx), return the final URL
 	if resp.StatusCode >= 200 && resp.StatusCode < 400 {
 		// Return the final URL after following redirects
-		return resp.Request.URL.String()
+		return resp.Request.URL.String(), fingerprintFromResponse(resp)
 	}
 
 	// 401 (auth required) is also valid - the web UI exists but needs login
 	if resp.StatusCode == 401 {
-		return resp.Request.URL.String()
+		return resp.Request.URL.String(), fingerprintFromResponse(resp)
 	}
 
-	return ""
+	return "", nil
 }
 
 // ParsePDUs converts a slice of SNMP PDUs into a PrinterInfo. It returns the
@@ -520,6 +539,7 @@ func ParsePDUs(scanIP string, vars []gosnmp.SnmpPDU, meta *ScanMeta, logFn func(
 	supplyDesc := map[string]string{}
 	supplyLevels := map[string]int{}
 	supplyMaxCap := map[string]int{} // max capacity for percentage calculation
+	supplyUnit := map[string]int{}   // prtMarkerSuppliesSupplyUnit for level and max capacity
 	var statusMsgs []string
 	ifMacs := map[string]string{}
 
@@ -718,6 +738,21 @@ func ParsePDUs(scanIP string, vars []gosnmp.SnmpPDU, meta *ScanMeta, logFn func(
 			}
 			continue
 		}
+		// supplies unit
+		if strings.HasPrefix(name, "1.3.6.1.2.1.43.11.1.1.7.1.") {
+			suf := strings.TrimPrefix(name, "1.3.6.1.2.1.43.11.1.1.7.1.")
+			parts := strings.Split(suf, ".")
+			key := suf
+			if len(parts) >= 2 {
+				if hrIdx, err := strconv.Atoi(parts[0]); err == nil {
+					key = fmt.Sprintf("%d.%s", hrIdx, strings.Join(parts[1:], "."))
+				}
+			}
+			if iv, ok := toInt(v.Value); ok {
+				supplyUnit[key] = iv
+			}
+			continue
+		}
 		// supplies max capacity
 		if strings.HasPrefix(name, "1.3.6.1.2.1.43.11.1.1.8.1.") {
 			suf := strings.TrimPrefix(name, "1.3.6.1.2.1.43.11.1.1.8.1.")
@@ -764,6 +799,7 @@ func ParsePDUs(scanIP string, vars []gosnmp.SnmpPDU, meta *ScanMeta, logFn func(
 	debug.Steps = append(debug.Steps, fmt.Sprintf("collected_counts: marker_counts=%d supply_desc=%d supply_levels=%d supply_max_cap=%d", len(markerCounts), len(supplyDesc), len(supplyLevels), len(supplyMaxCap)))
 
 	tonerLevels := map[string]int{}
+	supplyReadings := map[string]supplies.Reading{}
 	consumables := []string{}
 	// placeholders for per-color descs (raw descriptions for display)
 	var descBlack, descCyan, descMagenta, descYellow string
@@ -795,47 +831,34 @@ func ParsePDUs(scanIP string, vars []gosnmp.SnmpPDU, meta *ScanMeta, logFn func(
 				}
 			}
 
-			// Calculate percentage from level and max capacity per RFC 3805 (Printer-MIB)
-			// Level/MaxCapacity special values:
-			//   -1: other/unknown
-			//   -2: unknown (manufacturer-defined)
-			//   -3: someRemaining (level is not quantifiable, but supply is usable)
-			maxCap := supplyMaxCap[idx]
-			var percentage int
-			if maxCap > 0 && lvl >= 0 {
-				// Normal case: calculate percentage from level/capacity
-				percentage = int((float64(lvl) / float64(maxCap)) * 100.0)
-			} else if lvl >= 0 && lvl <= 100 && maxCap <= 0 {
-				// MaxCapacity is unknown/special but Level is valid 0-100 range
-				// Treat level as percentage directly (common for many printers)
-				percentage = lvl
-			} else if lvl == -3 {
-				// someRemaining: report as low (10%) as a reasonable estimate
-				percentage = 10
-			} else {
-				// Unknown or invalid level - skip this supply
+			// Percentage from level and max capacity in the reported unit per
+			// RFC 3805 (Printer-MIB); see supplies.Normalize
+			reading := supplies.Normalize(lvl, supplyMaxCap[idx], supplies.Unit(supplyUnit[idx]))
+			if !reading.HasPercent() {
+				// Unknown level or an absolute amount without capacity
+				if reading.ValueUnit != "" {
+					supplyReadings[key] = reading
+				}
 				consumables = append(consumables, desc)
 				continue
 			}
-			tonerLevels[key] = percentage
+			tonerLevels[key] = int(reading.Percent)
+			supplyReadings[key] = reading
 			consumables = append(consumables, desc)
 		} else {
 			// fallback to numeric index as string (idx is already a string)
 			key := idx
 			// Same percentage calculation for unknown supplies
-			maxCap := supplyMaxCap[idx]
-			var percentage int
-			if maxCap > 0 && lvl >= 0 {
-				percentage = int((float64(lvl) / float64(maxCap)) * 100.0)
-			} else if lvl >= 0 && lvl <= 100 && maxCap <= 0 {
-				percentage = lvl
-			} else if lvl == -3 {
-				percentage = 10
-			} else {
+			reading := supplies.Normalize(lvl, supplyMaxCap[idx], supplies.Unit(supplyUnit[idx]))
+			if !reading.HasPercent() {
+				if reading.ValueUnit != "" {
+					supplyReadings[key] = reading
+				}
 				consumables = append(consumables, key)
 				continue
 			}
-			tonerLevels[key] = percentage
+			tonerLevels[key] = int(reading.Percent)
+			supplyReadings[key] = reading
 			consumables = append(consumables, key)
 		}
 	}
@@ -923,9 +946,28 @@ func ParsePDUs(scanIP string, vars []gosnmp.SnmpPDU, meta *ScanMeta, logFn func(
 		reasons = append(reasons, "sysDescr")
 	}
 
-	// determine manufacturer: prefer sysObjectID enterprise roots, then sysDescr, then heuristic guesses
+	// determine manufacturer: prefer server-managed detection rules, then
+	// sysObjectID enterprise roots, then sysDescr, then heuristic guesses
 	manufacturer := ""
-	if soidPdu, ok := pduByOid["1.3.6.1.2.1.1.2.0"]; ok {
+	{
+		var soid, sdesc string
+		if p, ok := pduByOid["1.3.6.1.2.1.1.2.0"]; ok {
+			soid = pduToString(p.Value)
+		}
+		if p, ok := pduByOid["1.3.6.1.2.1.1.1.0"]; ok {
+			sdesc = pduToString(p.Value)
+		}
+		if m, ok := vendor.MatchDetectionRule(soid, sdesc); ok {
+			manufacturer = m.Manufacturer
+			prov["manufacturer"] = fmt.Sprintf("detection_rule:%d", m.RuleID)
+			debug.Steps = append(debug.Steps, fmt.Sprintf("manufacturer_from_rule id=%d val=%s", m.RuleID, manufacturer))
+			if m.Model != "" && (model == "" || model == modelGuess) {
+				model = m.Model
+				prov["model"] = fmt.Sprintf("detection_rule:%d", m.RuleID)
+			}
+		}
+	}
+	if soidPdu, ok := pduByOid["1.3.6.1.2.1.1.2.0"]; ok && manufacturer == "" {
 		soid := pduToString(soidPdu.Value)
 		if strings.Contains(soid, "1.3.6.1.4.1.11") {
 			manufacturer = "HP"
@@ -1042,6 +1084,16 @@ func ParsePDUs(scanIP string, vars []gosnmp.SnmpPDU, meta *ScanMeta, logFn func(
 		}
 	}
 	debug.ManufacturerHints = hints
+	if manufacturer == "" && isPrinter {
+		var soid, sdesc string
+		if p, ok := pduByOid["1.3.6.1.2.1.1.2.0"]; ok {
+			soid = pduToString(p.Value)
+		}
+		if p, ok := pduByOid["1.3.6.1.2.1.1.1.0"]; ok {
+			sdesc = pduToString(p.Value)
+		}
+		RecordUnknownDevice(scanIP, serial, soid, sdesc, model)
+	}
 
 	// persist a small flat log listing manufacturer-related OIDs for quick inspection
 	{
@@ -1222,6 +1274,7 @@ func ParsePDUs(scanIP string, vars []gosnmp.SnmpPDU, meta *ScanMeta, logFn func(
 		MagentaImpressions: markerCounts[4],
 		YellowImpressions:  markerCounts[5],
 		TonerLevels:        tonerLevels,
+		SupplyReadings:     supplyReadings,
 		Consumables:        consumables,
 		StatusMessages:     statusMsgs,
 		DetectionReasons:   reasons,
@@ -1234,6 +1287,9 @@ func ParsePDUs(scanIP string, vars []gosnmp.SnmpPDU, meta *ScanMeta, logFn func(
 		Hostname:           hostname,
 		LearnedOIDs:        learnedOIDs, // Store learned OIDs for efficient metrics queries
 	}
+	if soidPdu, ok := pduByOid["1.3.6.1.2.1.1.2.0"]; ok {
+		pi.SysObjectID = strings.TrimPrefix(pduToString(soidPdu.Value), ".")
+	}
 
 	// Log learned OIDs for improving scraper across different models/brands
 	if logFn != nil && (learnedOIDs.PageCountOID != "" || learnedOIDs.MonoPagesOID != "" || learnedOIDs.SerialOID != "" || learnedOIDs.ModelOID != "") {
@@ -1423,11 +1479,17 @@ func ParsePDUs(scanIP string, vars []gosnmp.SnmpPDU, meta *ScanMeta, logFn func(
 		pi.MAC = chosenMAC
 	}
 
+	// Record which SNMP credentials the device answered to (security posture)
+	pi.SNMPVersion, pi.SNMPDefaultCommunity = snmpAccessInfo()
+
 	// Detect web UI URL from open ports or SNMP data
-	webUIURL := detectWebUIURL(scanIP, meta, pduByOid)
+	webUIURL, webFP := detectWebUIURL(scanIP, meta, pduByOid)
 	if webUIURL != "" {
 		pi.WebUIURL = webUIURL
 	}
+	if !webFP.IsEmpty() {
+		pi.WebFingerprint = webFP
+	}
 
 	// finalize debug info and persist
 	debug.FinalManufacturer = manufacturer
@@ -1592,6 +1654,17 @@ func MergeVendorMetricsWithContext(ctx context.Context, pi *PrinterInfo, pdus []
 				pi.Meters["scans"] = v
 			}
 
+		// Raw supply readings behind the percentages below
+		case "supply_readings":
+			if readings, ok := value.(map[string]supplies.Reading); ok && len(readings) > 0 {
+				if pi.SupplyReadings == nil {
+					pi.SupplyReadings = make(map[string]supplies.Reading)
+				}
+				for k, r := range readings {
+					pi.SupplyReadings[k] = r
+				}
+			}
+
 		// Toner/ink levels
 		case "toner_black", "ink_black":
 			if v, ok := toIntValue(value); ok && v >= 0 {
@@ -1630,6 +1703,31 @@ func MergeVendorMetricsWithContext(ctx context.Context, pi *PrinterInfo, pdus []
 	}
 }
 
+// MergeLearnedMeters copies the values of learned vendor-specific OIDs into
+// pi.Meters under their field names, so counters mapped in the OID browser
+// (duplex_pages, nup_2in1, ...) reach the same normalization as vendor ones.
+func MergeLearnedMeters(pi *PrinterInfo, learned *LearnedOIDMap, pdus []gosnmp.SnmpPDU) {
+	if pi == nil || learned == nil || len(learned.VendorSpecificOIDs) == 0 {
+		return
+	}
+	byOID := make(map[string]gosnmp.SnmpPDU, len(pdus))
+	for _, pdu := range pdus {
+		byOID[strings.TrimPrefix(pdu.Name, ".")] = pdu
+	}
+	for field, oid := range learned.VendorSpecificOIDs {
+		pdu, ok := byOID[strings.TrimPrefix(oid, ".")]
+		if !ok {
+			continue
+		}
+		if v, ok := toIntValue(pdu.Value); ok && v > 0 {
+			if pi.Meters == nil {
+				pi.Meters = make(map[string]int)
+			}
+			pi.Meters[strings.ToLower(field)] = v
+		}
+	}
+}
+
 // toIntValue converts interface{} to int, handling common types
 func toIntValue(v interface{}) (int, bool) {
 	switch val := v.(type) {
@@ -3,6 +3,9 @@ package agent
 import (
 	"testing"
 
+	"printmaster/agent/scanner/vendor"
+	"printmaster/common/vendorrules"
+
 	"github.com/gosnmp/gosnmp"
 )
 
@@ -254,3 +257,64 @@ func TestParsePDUs_OIDValueNotUsedAsSerial(t *testing.T) {
 		t.Fatalf("expected serial to remain empty when only OID values present; got '%s'", pi.Serial)
 	}
 }
+
+func TestParsePDUs_DetectionRuleSetsManufacturerAndModel(t *testing.T) {
+	if err := vendor.SetDetectionRules([]vendorrules.Rule{
+		{ID: 3, Manufacturer: "Acme", SysObjectIDPrefix: "1.3.6.1.4.1.55555", ModelRegex: `MFP\s+\d+`, Enabled: true},
+	}); err != nil {
+		t.Fatalf("SetDetectionRules: %v", err)
+	}
+	t.Cleanup(func() { vendor.SetDetectionRules(nil) })
+	ResetUnknownDevices()
+	t.Cleanup(ResetUnknownDevices)
+
+	vars := []gosnmp.SnmpPDU{
+		{Name: "1.3.6.1.2.1.1.2.0", Type: gosnmp.ObjectIdentifier, Value: ".1.3.6.1.4.1.55555.1.2"},
+		{Name: "1.3.6.1.2.1.1.1.0", Type: gosnmp.OctetString, Value: []byte("Acme MFP 3000 firmware 1.2")},
+		{Name: "1.3.6.1.2.1.43.10.2.1.4.1.1", Type: gosnmp.Counter32, Value: uint(1234)},
+	}
+	pi, ok := ParsePDUs("10.9.9.11", vars, nil, nil)
+	if !ok {
+		t.Fatalf("expected device to be detected as printer")
+	}
+	if pi.Manufacturer != "Acme" || pi.Model != "MFP 3000" {
+		t.Fatalf("expected rule to set manufacturer and model, got %q %q", pi.Manufacturer, pi.Model)
+	}
+	if got := UnknownDevices(); len(got) != 0 {
+		t.Fatalf("device matched by a rule must not be recorded as unknown: %+v", got)
+	}
+}
+
+func TestParsePDUs_SupplyUnits(t *testing.T) {
+	t.Parallel()
+
+	vars := []gosnmp.SnmpPDU{
+		// Black toner in tenths of grams with a capacity: 1500/6000 = 25%
+		{Name: "1.3.6.1.2.1.43.11.1.1.6.1.1", Type: gosnmp.OctetString, Value: []byte("Black Toner")},
+		{Name: "1.3.6.1.2.1.43.11.1.1.7.1.1", Type: gosnmp.Integer, Value: 13},
+		{Name: "1.3.6.1.2.1.43.11.1.1.8.1.1", Type: gosnmp.Integer, Value: 6000},
+		{Name: "1.3.6.1.2.1.43.11.1.1.9.1.1", Type: gosnmp.Integer, Value: 1500},
+		// Drum reported in impressions without a capacity: no percentage
+		{Name: "1.3.6.1.2.1.43.11.1.1.6.1.2", Type: gosnmp.OctetString, Value: []byte("Drum Unit")},
+		{Name: "1.3.6.1.2.1.43.11.1.1.7.1.2", Type: gosnmp.Integer, Value: 7},
+		{Name: "1.3.6.1.2.1.43.11.1.1.8.1.2", Type: gosnmp.Integer, Value: -2},
+		{Name: "1.3.6.1.2.1.43.11.1.1.9.1.2", Type: gosnmp.Integer, Value: 42},
+	}
+
+	pi, ok := ParsePDUs("10.0.0.3", vars, nil, nil)
+	if !ok {
+		t.Fatalf("expected device to be detected as printer")
+	}
+	if got := pi.TonerLevels["toner_black"]; got != 25 {
+		t.Errorf("toner_black = %d, want 25", got)
+	}
+	if _, ok := pi.TonerLevels["drum_life"]; ok {
+		t.Errorf("drum_life should have no percentage, got %d", pi.TonerLevels["drum_life"])
+	}
+	if r := pi.SupplyReadings["toner_black"]; r.Unit != "tenthsOfGrams" || r.Value != 150 || r.ValueUnit != "g" {
+		t.Errorf("toner_black reading = %+v", r)
+	}
+	if r := pi.SupplyReadings["drum_life"]; r.Level != 42 || r.ValueUnit != "pages" || r.HasPercent() {
+		t.Errorf("drum_life reading = %+v", r)
+	}
+}
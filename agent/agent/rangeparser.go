@@ -5,7 +5,10 @@ import (
 	"fmt"
 	"net"
 	"os"
+	"strconv"
 	"strings"
+
+	"printmaster/agent/scanner"
 )
 
 // ParseError reports an error parsing a specific line
@@ -20,6 +23,8 @@ type ParseResult struct {
 	Count      int          `json:"count"`
 	Errors     []ParseError `json:"errors"`
 	Normalized []string     `json:"normalized"`
+	// Ports holds port overrides from explicit host:port entries, keyed by IP.
+	Ports map[string]scanner.PortOverride `json:"ports,omitempty"`
 }
 
 // helper: convert net.IP to uint32
@@ -45,7 +50,9 @@ func expandCIDRCount(ipnet *net.IPNet) int {
 
 // ParseRangeText parses the text (one entry per line) into a list of IPv4 addresses (strings). It enforces
 // a maximum number of addresses (maxAddrs). It supports: single IP, CIDR, full start-end, shorthand start-end
-// where right side supplies last N octets, and last-octet wildcard (x or *).
+// where right side supplies last N octets, and last-octet wildcard (x or *). A single IP may carry port
+// overrides for devices behind forwarded ports: "192.0.2.1:16161" sets the SNMP port, and trailing
+// "snmp=N" / "http=N" options set either port explicitly.
 func ParseRangeText(text string, maxAddrs int) (*ParseResult, error) {
 	res := &ParseResult{}
 	seen := map[string]struct{}{}
@@ -57,6 +64,19 @@ func ParseRangeText(text string, maxAddrs int) (*ParseResult, error) {
 			// preserve the raw line in normalized as-is for UI display
 			continue
 		}
+		var ports scanner.PortOverride
+		if strings.ContainsAny(s, ":=") {
+			host, p, err := splitPortTarget(s)
+			if err != nil {
+				res.Errors = append(res.Errors, ParseError{Line: lineNo, Msg: err.Error()})
+				continue
+			}
+			if ip := net.ParseIP(host); ip == nil || ip.To4() == nil {
+				res.Errors = append(res.Errors, ParseError{Line: lineNo, Msg: "ports can only be set on a single IPv4 address"})
+				continue
+			}
+			s, ports = host, p
+		}
 		// CIDR
 		if strings.Contains(s, "/") {
 			_, ipnet, err := net.ParseCIDR(s)
@@ -192,11 +212,64 @@ func ParseRangeText(text string, maxAddrs int) (*ParseResult, error) {
 			seen[ipstr] = struct{}{}
 			res.Count++
 		}
+		if !ports.IsZero() {
+			if res.Ports == nil {
+				res.Ports = make(map[string]scanner.PortOverride)
+			}
+			res.Ports[ipstr] = ports
+			res.Normalized = append(res.Normalized, formatPortTarget(ipstr, ports))
+			continue
+		}
 		res.Normalized = append(res.Normalized, ipstr)
 	}
 	return res, nil
 }
 
+// splitPortTarget parses "host[:snmpPort] [snmp=N] [http=N]".
+func splitPortTarget(s string) (string, scanner.PortOverride, error) {
+	var ports scanner.PortOverride
+	fields := strings.Fields(s)
+	host := fields[0]
+	if h, p, err := net.SplitHostPort(host); err == nil {
+		port, err := scanner.ParsePort(p)
+		if err != nil {
+			return "", ports, err
+		}
+		host, ports.SNMPPort = h, port
+	}
+	for _, opt := range fields[1:] {
+		key, val, ok := strings.Cut(opt, "=")
+		if !ok {
+			return "", ports, fmt.Errorf("unexpected %q, want snmp=PORT or http=PORT", opt)
+		}
+		port, err := scanner.ParsePort(val)
+		if err != nil {
+			return "", ports, err
+		}
+		switch strings.ToLower(key) {
+		case "snmp":
+			ports.SNMPPort = port
+		case "http":
+			ports.HTTPPort = port
+		default:
+			return "", ports, fmt.Errorf("unknown port option %q", key)
+		}
+	}
+	return host, ports, nil
+}
+
+// formatPortTarget renders an IP and its overrides in the form ParseRangeText accepts.
+func formatPortTarget(ip string, ports scanner.PortOverride) string {
+	out := ip
+	if ports.SNMPPort > 0 {
+		out = net.JoinHostPort(ip, strconv.Itoa(ports.SNMPPort))
+	}
+	if ports.HTTPPort > 0 {
+		out += " http=" + strconv.Itoa(ports.HTTPPort)
+	}
+	return out
+}
+
 // SaveConfig persists config to config.json in the current directory
 func SaveConfig(cfg interface{}) error {
 	fpath := "config.json"
@@ -50,3 +50,26 @@ func TestParseRangeText_Invalid(t *testing.T) {
 		t.Fatalf("expected parse errors for invalid input")
 	}
 }
+
+func TestParseRangeText_PortTargets(t *testing.T) {
+	txt := "192.0.2.1:16161 http=16100\n192.0.2.2 snmp=1161\n10.0.0.0/30:161\n192.0.2.3 ftp=21"
+	res, err := ParseRangeText(txt, 100)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if res.Count != 2 || res.IPs[0] != "192.0.2.1" || res.IPs[1] != "192.0.2.2" {
+		t.Fatalf("unexpected ips: %v", res.IPs)
+	}
+	if p := res.Ports["192.0.2.1"]; p.SNMPPort != 16161 || p.HTTPPort != 16100 {
+		t.Fatalf("unexpected ports for 192.0.2.1: %+v", p)
+	}
+	if p := res.Ports["192.0.2.2"]; p.SNMPPort != 1161 || p.HTTPPort != 0 {
+		t.Fatalf("unexpected ports for 192.0.2.2: %+v", p)
+	}
+	if res.Normalized[0] != "192.0.2.1:16161 http=16100" {
+		t.Fatalf("unexpected normalized entry: %q", res.Normalized[0])
+	}
+	if len(res.Errors) != 2 {
+		t.Fatalf("expected errors for CIDR with port and unknown option, got %v", res.Errors)
+	}
+}
@@ -0,0 +1,221 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// RelayEndpoint is a regional relay the server offers for uploads. Relays
+// accept the agent's batch uploads and forward them to the server, so agents
+// far from the server get faster, more reliable uploads.
+type RelayEndpoint struct {
+	ID     int64  `json:"id"`
+	Name   string `json:"name"`
+	Region string `json:"region,omitempty"`
+	URL    string `json:"url"`
+}
+
+const (
+	relayHealthPath      = "/relay/health"
+	relayProbeTimeout    = 5 * time.Second
+	relayReprobeInterval = 30 * time.Minute
+)
+
+// relayUploadPaths are the uploads sent through a relay. Everything else
+// (heartbeats, registration, meter reads that return data) goes directly to
+// the server.
+var relayUploadPaths = map[string]bool{
+	"/api/v1/devices/batch":         true,
+	"/api/v1/metrics/batch":         true,
+	"/api/v1/parse-samples/batch":   true,
+	"/api/v1/unknown-devices/batch": true,
+}
+
+// relayState tracks the relays offered by the server and the one in use.
+// Guarded by ServerClient.mu.
+type relayState struct {
+	offered  []RelayEndpoint
+	active   *RelayEndpoint
+	rtt      time.Duration
+	probedAt time.Time
+	probing  bool
+}
+
+// OfferRelays updates the relays the server offers. When the list changes,
+// or the last measurement is old, the agent measures the round-trip time to
+// each relay and to the server in the background and uploads through the
+// nearest relay, if one is closer than the server.
+func (c *ServerClient) OfferRelays(relays []RelayEndpoint) {
+	c.mu.Lock()
+	if len(relays) == 0 {
+		if c.relay.active != nil {
+			InfoCtx("Relay no longer offered, uploading directly to server", "relay", c.relay.active.Name)
+		}
+		c.relay = relayState{}
+		c.mu.Unlock()
+		return
+	}
+	if c.relay.probing || (sameRelays(c.relay.offered, relays) && time.Since(c.relay.probedAt) < relayReprobeInterval) {
+		c.mu.Unlock()
+		return
+	}
+	c.relay.offered = append([]RelayEndpoint(nil), relays...)
+	c.relay.probing = true
+	c.mu.Unlock()
+
+	go c.selectRelay(relays)
+}
+
+// ActiveRelay returns the relay uploads currently go through and its measured
+// round-trip time.
+func (c *ServerClient) ActiveRelay() (RelayEndpoint, time.Duration, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if c.relay.active == nil {
+		return RelayEndpoint{}, 0, false
+	}
+	return *c.relay.active, c.relay.rtt, true
+}
+
+func (c *ServerClient) selectRelay(relays []RelayEndpoint) {
+	ctx, cancel := context.WithTimeout(context.Background(), 2*relayProbeTimeout)
+	defer cancel()
+
+	direct := make(chan time.Duration, 1)
+	go func() { direct <- c.probeRTT(ctx, strings.TrimRight(c.BaseURL, "/")+"/health") }()
+	rtts := make([]time.Duration, len(relays))
+	var wg sync.WaitGroup
+	for i, r := range relays {
+		wg.Add(1)
+		go func(i int, r RelayEndpoint) {
+			defer wg.Done()
+			rtts[i] = c.probeRTT(ctx, strings.TrimRight(r.URL, "/")+relayHealthPath)
+		}(i, r)
+	}
+	wg.Wait()
+	directRTT := <-direct
+
+	best := -1
+	for i, rtt := range rtts {
+		if rtt > 0 && (best < 0 || rtt < rtts[best]) {
+			best = i
+		}
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.relay.probing = false
+	c.relay.probedAt = time.Now()
+	previous := c.relay.active
+	// A relay only helps when it is closer than the server itself
+	if best < 0 || (directRTT > 0 && rtts[best] >= directRTT) {
+		c.relay.active, c.relay.rtt = nil, 0
+		if previous != nil {
+			InfoCtx("Uploading directly to server; no relay is closer", "server_rtt", directRTT)
+		}
+		return
+	}
+	chosen := relays[best]
+	c.relay.active, c.relay.rtt = &chosen, rtts[best]
+	if previous == nil || previous.ID != chosen.ID {
+		InfoCtx("Uploading through relay", "relay", chosen.Name, "region", chosen.Region, "rtt", rtts[best], "server_rtt", directRTT)
+	}
+}
+
+// probeRTT returns the time a GET to url takes, or 0 if it fails.
+func (c *ServerClient) probeRTT(ctx context.Context, url string) time.Duration {
+	ctx, cancel := context.WithTimeout(ctx, relayProbeTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return 0
+	}
+	req.Header.Set("User-Agent", "PrintMaster-Agent/1.0")
+	start := time.Now()
+	resp, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return 0
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return 0
+	}
+	return time.Since(start)
+}
+
+// uploadTarget returns the base URL for a request and the relay it goes
+// through (0 when direct).
+func (c *ServerClient) uploadTarget(method, path string) (string, int64) {
+	if method != http.MethodPost || !relayUploadPaths[path] {
+		return c.BaseURL, 0
+	}
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if c.relay.active == nil {
+		return c.BaseURL, 0
+	}
+	return strings.TrimRight(c.relay.active.URL, "/"), c.relay.active.ID
+}
+
+// RelayReport returns the relay ID and round-trip time (ms) reported in
+// heartbeats; 0 when uploading directly.
+func (c *ServerClient) RelayReport() (int64, int) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if c.relay.active == nil {
+		return 0, 0
+	}
+	return c.relay.active.ID, int(c.relay.rtt / time.Millisecond)
+}
+
+// dropRelay stops using a relay that failed. The next heartbeat measures the
+// offered relays again.
+func (c *ServerClient) dropRelay(id int64, err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.relay.active == nil || c.relay.active.ID != id {
+		return
+	}
+	WarnCtx("Relay unavailable, uploading directly to server", "relay", c.relay.active.Name, "error", err)
+	c.relay.active, c.relay.rtt = nil, 0
+	c.relay.probedAt = time.Time{}
+}
+
+// relayUnavailable reports whether an upload through a relay should be
+// retried directly: the relay could not be reached or refused the upload.
+func relayUnavailable(err error) bool {
+	if err == nil || errors.Is(err, ErrNoToken) {
+		return false
+	}
+	var statusErr *StatusError
+	if errors.As(err, &statusErr) {
+		switch statusErr.StatusCode {
+		case http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout, http.StatusNotFound:
+			return true
+		}
+		return false
+	}
+	var throttled *ThrottledError
+	return !errors.As(err, &throttled)
+}
+
+func sameRelays(a, b []RelayEndpoint) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	seen := make(map[string]bool, len(a))
+	for _, r := range a {
+		seen[fmt.Sprintf("%d|%s", r.ID, r.URL)] = true
+	}
+	for _, r := range b {
+		if !seen[fmt.Sprintf("%d|%s", r.ID, r.URL)] {
+			return false
+		}
+	}
+	return true
+}
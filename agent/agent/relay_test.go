@@ -0,0 +1,129 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestServerClient_UploadsThroughNearestRelay(t *testing.T) {
+	t.Parallel()
+
+	var relayUploads, directUploads atomic.Int32
+	var reportedRelay atomic.Int64
+	relay := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case relayHealthPath:
+			w.Write([]byte(`{"status":"ok"}`))
+		case "/api/v1/metrics/batch":
+			relayUploads.Add(1)
+			w.WriteHeader(http.StatusAccepted)
+			w.Write([]byte(`{"success":true,"queued":true}`))
+		default:
+			t.Errorf("relay received unexpected request %s", r.URL.Path)
+			http.NotFound(w, r)
+		}
+	}))
+	unreachable := httptest.NewServer(http.NotFoundHandler())
+	unreachable.Close()
+
+	var relayURL atomic.Value
+	relayURL.Store(relay.URL)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/health":
+			time.Sleep(50 * time.Millisecond) // the server is far away
+			w.Write([]byte(`{"status":"ok"}`))
+		case "/api/v1/agents/heartbeat":
+			var req struct {
+				RelayID int64 `json:"relay_id"`
+			}
+			json.NewDecoder(r.Body).Decode(&req)
+			reportedRelay.Store(req.RelayID)
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"success": true,
+				"relays": []RelayEndpoint{
+					{ID: 7, Name: "near", URL: relayURL.Load().(string)},
+					{ID: 8, Name: "down", URL: unreachable.URL},
+				},
+			})
+		case "/api/v1/metrics/batch":
+			directUploads.Add(1)
+			w.Write([]byte(`{"success":true}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	client := NewServerClient(server.URL, "agent-1", "token")
+	ctx := context.Background()
+	if _, err := client.Heartbeat(ctx, ""); err != nil {
+		t.Fatalf("Heartbeat: %v", err)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		if r, _, ok := client.ActiveRelay(); ok {
+			if r.ID != 7 {
+				t.Fatalf("selected relay %+v, want the reachable one", r)
+			}
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("no relay selected")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	if err := client.UploadMetrics(ctx, []interface{}{map[string]interface{}{"serial": "SN1"}}); err != nil {
+		t.Fatalf("UploadMetrics via relay: %v", err)
+	}
+	if relayUploads.Load() != 1 || directUploads.Load() != 0 {
+		t.Fatalf("uploads: relay %d direct %d", relayUploads.Load(), directUploads.Load())
+	}
+	if _, err := client.Heartbeat(ctx, ""); err != nil {
+		t.Fatalf("Heartbeat: %v", err)
+	}
+	if reportedRelay.Load() != 7 {
+		t.Fatalf("heartbeat reported relay %d", reportedRelay.Load())
+	}
+
+	// A relay that goes away is dropped and the upload goes directly
+	relay.Close()
+	if err := client.UploadMetrics(ctx, []interface{}{map[string]interface{}{"serial": "SN1"}}); err != nil {
+		t.Fatalf("UploadMetrics after relay failure: %v", err)
+	}
+	if directUploads.Load() != 1 {
+		t.Fatalf("expected fallback to the server, direct uploads %d", directUploads.Load())
+	}
+	if _, _, ok := client.ActiveRelay(); ok {
+		t.Fatal("failed relay still active")
+	}
+}
+
+func TestServerClient_SkipsRelayFartherThanServer(t *testing.T) {
+	t.Parallel()
+
+	relay := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(50 * time.Millisecond)
+		w.Write([]byte(`{"status":"ok"}`))
+	}))
+	defer relay.Close()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"status":"ok"}`))
+	}))
+	defer server.Close()
+
+	client := NewServerClient(server.URL, "agent-1", "token")
+	client.selectRelay([]RelayEndpoint{{ID: 1, Name: "far", URL: relay.URL}})
+	if r, _, ok := client.ActiveRelay(); ok {
+		t.Fatalf("relay %s selected although the server is closer", r.Name)
+	}
+	if base, id := client.uploadTarget(http.MethodPost, "/api/v1/devices/batch"); base != server.URL || id != 0 {
+		t.Fatalf("uploadTarget = %s, %d", base, id)
+	}
+}
@@ -3,9 +3,12 @@ package agent
 import (
 	"bytes"
 	"context"
+	"crypto/rand"
 	"crypto/tls"
 	"crypto/x509"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
@@ -13,11 +16,14 @@ import (
 	"os"
 	"path/filepath"
 	"runtime"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
 
 	pmsettings "printmaster/common/settings"
+	"printmaster/common/updatepolicy"
+	"printmaster/common/vendorrules"
 )
 
 // ServerClient handles uploading agent data to the central PrintMaster server
@@ -33,6 +39,13 @@ type ServerClient struct {
 	lastHeartbeat      time.Time
 	lastDeviceUpload   time.Time
 	lastMetricsUpload  time.Time
+	uploadInterval     time.Duration // Reported with heartbeats so the server can suggest offsets
+	heartbeatInterval  time.Duration
+	effectiveSettings  *pmsettings.Settings // Reported with heartbeats so the server can detect drift
+	relay              relayState           // Regional relay used for batch uploads
+	runtimeEnvironment string               // container, systemd, windows_service, service or interactive
+	rotationNonce      string               // Sent with heartbeats; never persisted
+	meterReadPublicKey string               // Announced on join and with heartbeats
 }
 
 // SettingsSnapshot mirrors the server's managed settings payload.
@@ -41,12 +54,73 @@ type SettingsSnapshot struct {
 	SchemaVersion string              `json:"schema_version"`
 	UpdatedAt     time.Time           `json:"updated_at"`
 	Settings      pmsettings.Settings `json:"settings"`
+	FeatureFlags  map[string]bool     `json:"feature_flags,omitempty"`
+	// DetectionRules are the server-managed vendor detection rules
+	DetectionRules []vendorrules.Rule `json:"detection_rules,omitempty"`
+}
+
+// ThrottledError is returned when the server rejects a request because it is
+// overloaded (429). RetryAfter is the server's suggested wait.
+type ThrottledError struct {
+	RetryAfter time.Duration
+	Message    string
+}
+
+func (e *ThrottledError) Error() string {
+	return fmt.Sprintf("server busy, retry after %s: %s", e.RetryAfter, e.Message)
+}
+
+// StatusError is returned when the server answers with an unexpected non-2xx
+// status.
+type StatusError struct {
+	StatusCode int
+	Body       string
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("server returned status %d: %s", e.StatusCode, e.Body)
+}
+
+// ErrNoToken is returned for authenticated requests made before the agent
+// has a token.
+var ErrNoToken = errors.New("authentication required but no token available")
+
+// parseRetryAfter reads a Retry-After header given in seconds or as an HTTP
+// date, falling back to 30 seconds.
+func parseRetryAfter(value string) time.Duration {
+	value = strings.TrimSpace(value)
+	if secs, err := strconv.Atoi(value); err == nil && secs >= 0 {
+		return time.Duration(secs) * time.Second
+	}
+	if at, err := http.ParseTime(value); err == nil {
+		if d := time.Until(at); d > 0 {
+			return d.Round(time.Second)
+		}
+		return 0
+	}
+	return 30 * time.Second
 }
 
 // HeartbeatResult captures metadata returned from a heartbeat call.
 type HeartbeatResult struct {
 	SettingsVersion string
 	Snapshot        *SettingsSnapshot
+	// Enforce is set when an admin re-enforced policy; Snapshot must be
+	// re-applied even if its version matches the current one.
+	Enforce  bool
+	Schedule *ScheduleHint
+	// AgentToken is set when the server rotated this agent's token. It must be
+	// persisted before use; the old token keeps working for a short grace period.
+	AgentToken          string
+	AgentTokenExpiresAt time.Time
+}
+
+// ScheduleHint is the server's suggested phase for this agent's periodic work,
+// as offsets within the upload and heartbeat intervals. Spreading agents across
+// their intervals keeps a large fleet from hitting the server at the same time.
+type ScheduleHint struct {
+	UploadOffsetSeconds    int `json:"upload_offset_seconds"`
+	HeartbeatOffsetSeconds int `json:"heartbeat_offset_seconds"`
 }
 
 // NewServerClient creates a new server uploader for this agent
@@ -105,6 +179,7 @@ func NewServerClientWithName(baseURL, agentID, agentName, token, caCertPath stri
 		HTTPClient: &http.Client{
 			Timeout: 30 * time.Second,
 			Transport: &http.Transport{
+				Proxy:           ProxyForServer,
 				TLSClientConfig: tlsConfig,
 			},
 		},
@@ -221,6 +296,8 @@ func (c *ServerClient) RegisterWithToken(ctx context.Context, joinToken string,
 		Name            string `json:"name,omitempty"`
 		AgentVersion    string `json:"agent_version,omitempty"`
 		ProtocolVersion string `json:"protocol_version,omitempty"`
+		// Registered with the join so meter reads verify from the start
+		MeterReadPublicKey string `json:"meter_read_public_key,omitempty"`
 	}
 
 	type JoinResponse struct {
@@ -239,6 +316,7 @@ func (c *ServerClient) RegisterWithToken(ctx context.Context, joinToken string,
 		AgentVersion:    version,
 		ProtocolVersion: "1",
 	}
+	req.MeterReadPublicKey = c.MeterReadPublicKey()
 
 	var resp JoinResponse
 	if err := c.doRequest(ctx, "POST", "/api/v1/agents/register-with-token", req, &resp, false); err != nil {
@@ -345,6 +423,63 @@ func (c *ServerClient) Heartbeat(ctx context.Context, settingsVersion string) (*
 	return c.HeartbeatWithVersion(ctx, settingsVersion, nil)
 }
 
+// SetScheduleIntervals records the agent's upload and heartbeat intervals,
+// which are reported with each heartbeat so the server's schedule hint fits them.
+func (c *ServerClient) SetScheduleIntervals(upload, heartbeat time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.uploadInterval = upload
+	c.heartbeatInterval = heartbeat
+}
+
+// SetRuntimeEnvironment records where the agent runs, which is reported with
+// each heartbeat.
+func (c *ServerClient) SetRuntimeEnvironment(env string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.runtimeEnvironment = env
+}
+
+// SetMeterReadPublicKey sets the public key (see meterread.EncodePublicKey)
+// the agent signs meter reads with, announced so the server can verify them.
+func (c *ServerClient) SetMeterReadPublicKey(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.meterReadPublicKey = key
+}
+
+// MeterReadPublicKey returns the key set by SetMeterReadPublicKey.
+func (c *ServerClient) MeterReadPublicKey() string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.meterReadPublicKey
+}
+
+// RotationNonce returns a random nonce generated once per process and sent
+// with every heartbeat. The server only repeats a rotated token to a
+// heartbeat carrying the nonce of the one that rotated it, so a copy of the
+// bearer token alone cannot collect its successor.
+func (c *ServerClient) RotationNonce() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.rotationNonce == "" {
+		buf := make([]byte, 16)
+		if _, err := rand.Read(buf); err != nil {
+			return ""
+		}
+		c.rotationNonce = hex.EncodeToString(buf)
+	}
+	return c.rotationNonce
+}
+
+// SetEffectiveSettings records the settings the agent is running with, which
+// are reported with each heartbeat so the server can compare them to policy.
+func (c *ServerClient) SetEffectiveSettings(cfg *pmsettings.Settings) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.effectiveSettings = cfg
+}
+
 // HeartbeatWithVersion sends a heartbeat with optional version info to update server-side agent metadata.
 func (c *ServerClient) HeartbeatWithVersion(ctx context.Context, settingsVersion string, versionInfo *AgentVersionInfo) (*HeartbeatResult, error) {
 	type HeartbeatRequest struct {
@@ -363,12 +498,32 @@ func (c *ServerClient) HeartbeatWithVersion(ctx context.Context, settingsVersion
 		Architecture    string `json:"architecture,omitempty"`
 		BuildType       string `json:"build_type,omitempty"`
 		GitCommit       string `json:"git_commit,omitempty"`
+		// Schedule intervals - used by the server to suggest offsets
+		UploadIntervalSeconds    int `json:"upload_interval_seconds,omitempty"`
+		HeartbeatIntervalSeconds int `json:"heartbeat_interval_seconds,omitempty"`
+		// Tells the server this agent persists rotated tokens
+		TokenRotation      bool   `json:"token_rotation"`
+		TokenRotationNonce string `json:"token_rotation_nonce,omitempty"`
+		// Key meter reads are signed with
+		MeterReadPublicKey string `json:"meter_read_public_key,omitempty"`
+		// Settings in effect, compared against policy for drift detection
+		EffectiveSettings *pmsettings.Settings `json:"effective_settings,omitempty"`
+		// Relay used for uploads, if any
+		RelayID    int64 `json:"relay_id,omitempty"`
+		RelayRTTMS int   `json:"relay_rtt_ms,omitempty"`
+		// Where the agent runs
+		RuntimeEnvironment string `json:"runtime_environment,omitempty"`
 	}
 
 	type HeartbeatResponse struct {
-		Success          bool              `json:"success"`
-		SettingsVersion  string            `json:"settings_version,omitempty"`
-		SettingsSnapshot *SettingsSnapshot `json:"settings_snapshot,omitempty"`
+		Success             bool              `json:"success"`
+		SettingsVersion     string            `json:"settings_version,omitempty"`
+		SettingsSnapshot    *SettingsSnapshot `json:"settings_snapshot,omitempty"`
+		SettingsEnforce     bool              `json:"settings_enforce,omitempty"`
+		Schedule            *ScheduleHint     `json:"schedule,omitempty"`
+		AgentToken          string            `json:"agent_token,omitempty"`
+		AgentTokenExpiresAt time.Time         `json:"agent_token_expires_at,omitempty"`
+		Relays              []RelayEndpoint   `json:"relays,omitempty"`
 	}
 
 	hostname, _ := os.Hostname()
@@ -382,7 +537,18 @@ func (c *ServerClient) HeartbeatWithVersion(ctx context.Context, settingsVersion
 		Platform:        runtime.GOOS,
 		Architecture:    runtime.GOARCH,
 		GoVersion:       runtime.Version(),
+		TokenRotation:   true,
 	}
+	req.TokenRotationNonce = c.RotationNonce()
+
+	c.mu.RLock()
+	req.UploadIntervalSeconds = int(c.uploadInterval / time.Second)
+	req.HeartbeatIntervalSeconds = int(c.heartbeatInterval / time.Second)
+	req.EffectiveSettings = c.effectiveSettings
+	req.RuntimeEnvironment = c.runtimeEnvironment
+	req.MeterReadPublicKey = c.meterReadPublicKey
+	c.mu.RUnlock()
+	req.RelayID, req.RelayRTTMS = c.RelayReport()
 
 	// Include version info if provided
 	if versionInfo != nil {
@@ -403,10 +569,15 @@ func (c *ServerClient) HeartbeatWithVersion(ctx context.Context, settingsVersion
 	c.mu.Lock()
 	c.lastHeartbeat = time.Now()
 	c.mu.Unlock()
+	c.OfferRelays(resp.Relays)
 
 	return &HeartbeatResult{
-		SettingsVersion: resp.SettingsVersion,
-		Snapshot:        resp.SettingsSnapshot,
+		SettingsVersion:     resp.SettingsVersion,
+		Snapshot:            resp.SettingsSnapshot,
+		Enforce:             resp.SettingsEnforce,
+		Schedule:            resp.Schedule,
+		AgentToken:          resp.AgentToken,
+		AgentTokenExpiresAt: resp.AgentTokenExpiresAt,
 	}, nil
 }
 
@@ -462,6 +633,69 @@ func (c *ServerClient) UploadMetrics(ctx context.Context, metrics []interface{})
 	return nil
 }
 
+// MeterReadUploadResult reports which uploaded meter reads the server
+// accepted (including ones it already had) and which it rejected.
+type MeterReadUploadResult struct {
+	Accepted []string `json:"accepted"`
+	Rejected []struct {
+		ID     string `json:"id"`
+		Reason string `json:"reason"`
+	} `json:"rejected"`
+}
+
+// UploadMeterReads sends certified meter reads to the server
+func (c *ServerClient) UploadMeterReads(ctx context.Context, reads []interface{}) (*MeterReadUploadResult, error) {
+	req := struct {
+		AgentID string        `json:"agent_id"`
+		Reads   []interface{} `json:"reads"`
+	}{
+		AgentID: c.AgentID,
+		Reads:   reads,
+	}
+
+	var resp MeterReadUploadResult
+	if err := c.doRequest(ctx, "POST", "/api/v1/meter-reads/batch", req, &resp, true); err != nil {
+		return nil, fmt.Errorf("meter read upload failed: %w", err)
+	}
+	return &resp, nil
+}
+
+// UploadParseSamples sends parse-debug samples for devices with parsing
+// gaps to the server's data quality dashboard
+func (c *ServerClient) UploadParseSamples(ctx context.Context, samples []interface{}) error {
+	req := struct {
+		AgentID string        `json:"agent_id"`
+		Samples []interface{} `json:"samples"`
+	}{
+		AgentID: c.AgentID,
+		Samples: samples,
+	}
+
+	var resp map[string]interface{}
+	if err := c.doRequest(ctx, "POST", "/api/v1/parse-samples/batch", req, &resp, true); err != nil {
+		return fmt.Errorf("parse sample upload failed: %w", err)
+	}
+	return nil
+}
+
+// UploadUnknownDevices sends anonymized samples of printers with no
+// recognized manufacturer to the server.
+func (c *ServerClient) UploadUnknownDevices(ctx context.Context, devices []UnknownDevice) error {
+	req := struct {
+		AgentID string          `json:"agent_id"`
+		Devices []UnknownDevice `json:"devices"`
+	}{
+		AgentID: c.AgentID,
+		Devices: devices,
+	}
+
+	var resp map[string]interface{}
+	if err := c.doRequest(ctx, "POST", "/api/v1/unknown-devices/batch", req, &resp, true); err != nil {
+		return fmt.Errorf("unknown device upload failed: %w", err)
+	}
+	return nil
+}
+
 // LogAuditEvent sends an audit log entry to the server
 func (c *ServerClient) LogAuditEvent(ctx context.Context, action, resourceType, resourceID string, details map[string]interface{}) error {
 	type AuditRequest struct {
@@ -491,6 +725,41 @@ func (c *ServerClient) LogAuditEvent(ctx context.Context, action, resourceType,
 	return nil
 }
 
+// Alert report states.
+const (
+	AlertReportRaised   = "raised"
+	AlertReportResolved = "resolved"
+)
+
+// AlertReport is an alert raised or resolved by the agent's local alert
+// engine, forwarded to the server.
+type AlertReport struct {
+	State        string    `json:"state"` // raised or resolved
+	Type         string    `json:"type"`
+	Severity     string    `json:"severity"`
+	DeviceSerial string    `json:"device_serial"`
+	DeviceIP     string    `json:"device_ip,omitempty"`
+	Title        string    `json:"title"`
+	Message      string    `json:"message,omitempty"`
+	At           time.Time `json:"at"`
+}
+
+// ReportAlerts forwards local alert changes to the server
+func (c *ServerClient) ReportAlerts(ctx context.Context, reports []AlertReport) error {
+	if len(reports) == 0 {
+		return nil
+	}
+	req := map[string]interface{}{
+		"agent_id": c.AgentID,
+		"alerts":   reports,
+	}
+	var resp map[string]interface{}
+	if err := c.doRequest(ctx, "POST", "/api/v1/agents/alerts", req, &resp, true); err != nil {
+		return fmt.Errorf("alert report failed: %w", err)
+	}
+	return nil
+}
+
 // DeviceCredentials holds web UI credentials for auto-login
 type DeviceCredentials struct {
 	Exists    bool   `json:"exists"`
@@ -536,7 +805,17 @@ func (c *ServerClient) GetStats() map[string]interface{} {
 
 // doRequest performs an HTTP request with optional authentication
 func (c *ServerClient) doRequest(ctx context.Context, method, path string, reqBody, respBody interface{}, requireAuth bool) error {
-	url := c.BaseURL + path
+	base, relayID := c.uploadTarget(method, path)
+	err := c.doRequestTo(ctx, base, method, path, reqBody, respBody, requireAuth)
+	if relayID != 0 && ctx.Err() == nil && relayUnavailable(err) {
+		c.dropRelay(relayID, err)
+		return c.doRequestTo(ctx, c.BaseURL, method, path, reqBody, respBody, requireAuth)
+	}
+	return err
+}
+
+func (c *ServerClient) doRequestTo(ctx context.Context, base, method, path string, reqBody, respBody interface{}, requireAuth bool) error {
+	url := base + path
 
 	// Encode request body
 	var bodyReader io.Reader
@@ -561,7 +840,7 @@ func (c *ServerClient) doRequest(ctx context.Context, method, path string, reqBo
 	if requireAuth {
 		token := c.GetToken()
 		if token == "" {
-			return fmt.Errorf("authentication required but no token available")
+			return ErrNoToken
 		}
 		httpReq.Header.Set("Authorization", "Bearer "+token)
 	}
@@ -589,9 +868,14 @@ func (c *ServerClient) doRequest(ctx context.Context, method, path string, reqBo
 	}
 
 	// Check status code
+	if httpResp.StatusCode == http.StatusTooManyRequests {
+		retryAfter := parseRetryAfter(httpResp.Header.Get("Retry-After"))
+		Warn(fmt.Sprintf("Server is busy (429) for %s %s, retry after %s", method, url, retryAfter))
+		return &ThrottledError{RetryAfter: retryAfter, Message: strings.TrimSpace(string(respData))}
+	}
 	if httpResp.StatusCode < 200 || httpResp.StatusCode >= 300 {
 		Error(fmt.Sprintf("Server returned non-2xx status %d for %s %s: %s", httpResp.StatusCode, method, url, string(respData)))
-		return fmt.Errorf("server returned status %d: %s", httpResp.StatusCode, string(respData))
+		return &StatusError{StatusCode: httpResp.StatusCode, Body: string(respData)}
 	}
 
 	// Decode response if needed
@@ -810,17 +1094,19 @@ func (c *ServerClient) DownloadArtifactWithProgress(ctx context.Context, manifes
 }
 
 // ReportUpdateStatus sends telemetry about an update operation to the server.
-func (c *ServerClient) ReportUpdateStatus(ctx context.Context, status, runID, currentVersion, targetVersion string, errCode, errMsg string, metadata map[string]interface{}) error {
+// Diagnostics is only set for failures.
+func (c *ServerClient) ReportUpdateStatus(ctx context.Context, status, runID, currentVersion, targetVersion string, errCode, errMsg string, metadata map[string]interface{}, diagnostics *updatepolicy.FailureDiagnostics) error {
 	type TelemetryRequest struct {
-		AgentID        string                 `json:"agent_id"`
-		RunID          string                 `json:"run_id,omitempty"`
-		Status         string                 `json:"status"`
-		CurrentVersion string                 `json:"current_version"`
-		TargetVersion  string                 `json:"target_version,omitempty"`
-		ErrorCode      string                 `json:"error_code,omitempty"`
-		ErrorMessage   string                 `json:"error_message,omitempty"`
-		Timestamp      time.Time              `json:"timestamp"`
-		Metadata       map[string]interface{} `json:"metadata,omitempty"`
+		AgentID        string                           `json:"agent_id"`
+		RunID          string                           `json:"run_id,omitempty"`
+		Status         string                           `json:"status"`
+		CurrentVersion string                           `json:"current_version"`
+		TargetVersion  string                           `json:"target_version,omitempty"`
+		ErrorCode      string                           `json:"error_code,omitempty"`
+		ErrorMessage   string                           `json:"error_message,omitempty"`
+		Timestamp      time.Time                        `json:"timestamp"`
+		Metadata       map[string]interface{}           `json:"metadata,omitempty"`
+		Diagnostics    *updatepolicy.FailureDiagnostics `json:"diagnostics,omitempty"`
 	}
 
 	req := TelemetryRequest{
@@ -833,6 +1119,7 @@ func (c *ServerClient) ReportUpdateStatus(ctx context.Context, status, runID, cu
 		ErrorMessage:   errMsg,
 		Timestamp:      time.Now(),
 		Metadata:       metadata,
+		Diagnostics:    diagnostics,
 	}
 
 	var resp map[string]interface{}
@@ -3,6 +3,7 @@ package agent
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"net/http"
 	"net/http/httptest"
 	"testing"
@@ -256,6 +257,30 @@ func TestServerClient_Unauthorized(t *testing.T) {
 	}
 }
 
+func TestServerClient_ThrottledUpload(t *testing.T) {
+	t.Parallel()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Retry-After", "12")
+		http.Error(w, "ingest queue full, retry later", http.StatusTooManyRequests)
+	}))
+	defer server.Close()
+
+	client := NewServerClient(server.URL, "test-agent", "test-token")
+	err := client.UploadDevices(context.Background(), []interface{}{map[string]interface{}{"serial": "S1"}})
+
+	var throttled *ThrottledError
+	if !errors.As(err, &throttled) {
+		t.Fatalf("Expected ThrottledError, got %v", err)
+	}
+	if throttled.RetryAfter != 12*time.Second {
+		t.Errorf("Expected RetryAfter 12s, got %s", throttled.RetryAfter)
+	}
+	if got := parseRetryAfter("garbage"); got != 30*time.Second {
+		t.Errorf("Expected fallback of 30s, got %s", got)
+	}
+}
+
 func TestServerClient_Timeout(t *testing.T) {
 	t.Parallel()
 
@@ -0,0 +1,237 @@
+package agent
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ServerHealth classifies the agent's connectivity to the server.
+type ServerHealth string
+
+const (
+	ServerHealthUnknown          ServerHealth = "unknown"           // No request has completed yet
+	ServerHealthOK               ServerHealth = "ok"                // Last request succeeded
+	ServerHealthDown             ServerHealth = "server_down"       // Unreachable, timed out or 5xx
+	ServerHealthAuthInvalid      ServerHealth = "auth_invalid"      // Token missing, rejected or revoked
+	ServerHealthTLSFailure       ServerHealth = "tls_error"         // Certificate or handshake failure
+	ServerHealthProtocolMismatch ServerHealth = "protocol_mismatch" // Server does not speak this agent's API
+)
+
+// Transient reports whether the condition may clear without changes on the
+// agent (a restarted server), as opposed to needing new credentials,
+// certificates or a compatible server version.
+func (h ServerHealth) Transient() bool {
+	return h == ServerHealthDown
+}
+
+// Message returns a short human readable description.
+func (h ServerHealth) Message() string {
+	switch h {
+	case ServerHealthOK:
+		return "Connected"
+	case ServerHealthDown:
+		return "Server unreachable"
+	case ServerHealthAuthInvalid:
+		return "Server rejected the agent token"
+	case ServerHealthTLSFailure:
+		return "TLS certificate or handshake failure"
+	case ServerHealthProtocolMismatch:
+		return "Server API is incompatible with this agent"
+	default:
+		return "Not connected yet"
+	}
+}
+
+// ClassifyServerError maps an error from ServerClient to a health status.
+// Throttling (429) means the server is up and returns ServerHealthOK.
+func ClassifyServerError(err error) ServerHealth {
+	if err == nil {
+		return ServerHealthOK
+	}
+	var throttled *ThrottledError
+	if errors.As(err, &throttled) {
+		return ServerHealthOK
+	}
+	if errors.Is(err, ErrNoToken) {
+		return ServerHealthAuthInvalid
+	}
+	var statusErr *StatusError
+	if errors.As(err, &statusErr) {
+		switch statusErr.StatusCode {
+		case http.StatusUnauthorized, http.StatusForbidden:
+			return ServerHealthAuthInvalid
+		case http.StatusNotFound, http.StatusMethodNotAllowed, http.StatusNotAcceptable,
+			http.StatusUnsupportedMediaType, http.StatusUpgradeRequired, http.StatusNotImplemented:
+			return ServerHealthProtocolMismatch
+		}
+		return ServerHealthDown
+	}
+	if isTLSError(err) {
+		return ServerHealthTLSFailure
+	}
+	var syntaxErr *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+	if errors.As(err, &syntaxErr) || errors.As(err, &typeErr) {
+		// Usually an HTML page from a proxy or an older server's payload
+		return ServerHealthProtocolMismatch
+	}
+	return ServerHealthDown
+}
+
+func isTLSError(err error) bool {
+	var (
+		unknownAuthority x509.UnknownAuthorityError
+		hostnameErr      x509.HostnameError
+		certInvalid      x509.CertificateInvalidError
+		verifyErr        *tls.CertificateVerificationError
+		recordErr        tls.RecordHeaderError
+		alertErr         tls.AlertError
+	)
+	if errors.As(err, &unknownAuthority) || errors.As(err, &hostnameErr) || errors.As(err, &certInvalid) ||
+		errors.As(err, &verifyErr) || errors.As(err, &recordErr) || errors.As(err, &alertErr) {
+		return true
+	}
+	// Handshake failures are not always typed
+	msg := strings.ToLower(err.Error())
+	return strings.Contains(msg, "tls: ") || strings.Contains(msg, "x509: ")
+}
+
+// Circuit breaker timing. Transient failures open the breaker after a few
+// consecutive attempts; permanent ones open it immediately since retrying
+// cannot help until something changes.
+const (
+	breakerFailureThreshold = 3
+	breakerInitialInterval  = 30 * time.Second
+	breakerMaxInterval      = 30 * time.Minute
+)
+
+// ServerHealthSnapshot is the breaker state surfaced in status APIs.
+type ServerHealthSnapshot struct {
+	Status              ServerHealth `json:"status"`
+	Message             string       `json:"message"`
+	LastError           string       `json:"last_error,omitempty"`
+	ConsecutiveFailures int          `json:"consecutive_failures"`
+	BreakerOpen         bool         `json:"breaker_open"`
+	NextAttempt         *time.Time   `json:"next_attempt,omitempty"`
+	LastSuccess         *time.Time   `json:"last_success,omitempty"`
+	LastFailure         *time.Time   `json:"last_failure,omitempty"`
+}
+
+// CircuitBreaker pauses server traffic after repeated failures, doubling
+// the pause each time a trial request fails.
+type CircuitBreaker struct {
+	mu          sync.Mutex
+	now         func() time.Time
+	status      ServerHealth
+	lastError   string
+	failures    int
+	open        bool
+	openUntil   time.Time
+	interval    time.Duration
+	lastSuccess time.Time
+	lastFailure time.Time
+}
+
+// NewCircuitBreaker returns a closed breaker.
+func NewCircuitBreaker() *CircuitBreaker {
+	return &CircuitBreaker{now: time.Now, status: ServerHealthUnknown}
+}
+
+// Allow reports whether a request may be sent now. Once the pause has
+// elapsed requests are let through as a trial; the next result decides
+// whether the breaker closes or reopens for longer.
+func (b *CircuitBreaker) Allow() bool {
+	if b == nil {
+		return true
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return !b.open || !b.now().Before(b.openUntil)
+}
+
+// Record updates the breaker with the result of a server request.
+func (b *CircuitBreaker) Record(err error) {
+	if b == nil {
+		return
+	}
+	health := ClassifyServerError(err)
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	now := b.now()
+	if health == ServerHealthOK {
+		b.status = ServerHealthOK
+		b.lastError = ""
+		b.failures = 0
+		b.open = false
+		b.interval = 0
+		b.lastSuccess = now
+		return
+	}
+
+	b.status = health
+	b.lastError = err.Error()
+	b.lastFailure = now
+	if b.open && now.Before(b.openUntil) {
+		// A request that was already in flight when the breaker opened
+		return
+	}
+	b.failures++
+	if health.Transient() && !b.open && b.failures < breakerFailureThreshold {
+		return
+	}
+	switch {
+	case b.interval == 0:
+		b.interval = breakerInitialInterval
+	case b.open:
+		b.interval = min(b.interval*2, breakerMaxInterval)
+	}
+	b.open = true
+	b.openUntil = now.Add(b.interval)
+}
+
+// Reset closes the breaker so the next request is sent immediately, keeping
+// the last status until that request completes.
+func (b *CircuitBreaker) Reset() {
+	if b == nil {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.open = false
+	b.failures = 0
+}
+
+// Snapshot returns the current state.
+func (b *CircuitBreaker) Snapshot() ServerHealthSnapshot {
+	if b == nil {
+		return ServerHealthSnapshot{Status: ServerHealthUnknown, Message: ServerHealthUnknown.Message()}
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	snap := ServerHealthSnapshot{
+		Status:              b.status,
+		Message:             b.status.Message(),
+		LastError:           b.lastError,
+		ConsecutiveFailures: b.failures,
+		BreakerOpen:         b.open,
+	}
+	if b.open {
+		next := b.openUntil
+		snap.NextAttempt = &next
+	}
+	if !b.lastSuccess.IsZero() {
+		t := b.lastSuccess
+		snap.LastSuccess = &t
+	}
+	if !b.lastFailure.IsZero() {
+		t := b.lastFailure
+		snap.LastFailure = &t
+	}
+	return snap
+}
@@ -0,0 +1,120 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestClassifyServerError(t *testing.T) {
+	t.Parallel()
+
+	respond := func(status int, body string) error {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(status)
+			fmt.Fprint(w, body)
+		}))
+		defer server.Close()
+		client := NewServerClient(server.URL, "test-agent", "test-token")
+		_, err := client.Heartbeat(context.Background(), "")
+		return err
+	}
+
+	cases := []struct {
+		name string
+		err  error
+		want ServerHealth
+	}{
+		{"success", nil, ServerHealthOK},
+		{"unauthorized", respond(http.StatusUnauthorized, "invalid token"), ServerHealthAuthInvalid},
+		{"missing endpoint", respond(http.StatusNotFound, "not found"), ServerHealthProtocolMismatch},
+		{"server error", respond(http.StatusBadGateway, "bad gateway"), ServerHealthDown},
+		{"html body", respond(http.StatusOK, "<html>captive portal</html>"), ServerHealthProtocolMismatch},
+		{"throttled", &ThrottledError{RetryAfter: time.Second}, ServerHealthOK},
+		{"no token", fmt.Errorf("heartbeat failed: %w", ErrNoToken), ServerHealthAuthInvalid},
+		{"refused", errors.New("dial tcp 127.0.0.1:1: connect: connection refused"), ServerHealthDown},
+	}
+	for _, tc := range cases {
+		if got := ClassifyServerError(tc.err); got != tc.want {
+			t.Errorf("%s: got %s, want %s (err: %v)", tc.name, got, tc.want, tc.err)
+		}
+	}
+}
+
+func TestClassifyServerErrorTLS(t *testing.T) {
+	t.Parallel()
+
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+	client := NewServerClient(server.URL, "test-agent", "test-token")
+	_, err := client.Heartbeat(context.Background(), "")
+	if got := ClassifyServerError(err); got != ServerHealthTLSFailure {
+		t.Fatalf("untrusted certificate: got %s (err: %v)", got, err)
+	}
+}
+
+func TestCircuitBreakerBackoff(t *testing.T) {
+	t.Parallel()
+
+	now := time.Unix(1000, 0)
+	b := NewCircuitBreaker()
+	b.now = func() time.Time { return now }
+	down := &StatusError{StatusCode: http.StatusServiceUnavailable}
+
+	// Transient failures only open the breaker at the threshold
+	for i := 1; i < breakerFailureThreshold; i++ {
+		b.Record(down)
+		if !b.Allow() {
+			t.Fatalf("breaker opened after %d failures", i)
+		}
+	}
+	b.Record(down)
+	if b.Allow() {
+		t.Fatal("breaker should be open at the threshold")
+	}
+	snap := b.Snapshot()
+	if snap.Status != ServerHealthDown || !snap.BreakerOpen || snap.NextAttempt == nil {
+		t.Fatalf("unexpected snapshot: %+v", snap)
+	}
+
+	// A failed trial doubles the pause
+	now = now.Add(breakerInitialInterval)
+	if !b.Allow() {
+		t.Fatal("trial request should be allowed once the pause elapses")
+	}
+	b.Record(down)
+	if want := now.Add(2 * breakerInitialInterval); !b.Snapshot().NextAttempt.Equal(want) {
+		t.Fatalf("next attempt %v, want %v", b.Snapshot().NextAttempt, want)
+	}
+
+	// Success closes it again
+	now = now.Add(2 * breakerInitialInterval)
+	b.Record(nil)
+	if snap := b.Snapshot(); snap.BreakerOpen || snap.Status != ServerHealthOK || snap.ConsecutiveFailures != 0 {
+		t.Fatalf("breaker should be closed after success: %+v", snap)
+	}
+}
+
+func TestCircuitBreakerPermanentFailureAndReset(t *testing.T) {
+	t.Parallel()
+
+	b := NewCircuitBreaker()
+	b.Record(&StatusError{StatusCode: http.StatusUnauthorized})
+	if b.Allow() {
+		t.Fatal("auth failures should open the breaker immediately")
+	}
+	if got := b.Snapshot().Status; got != ServerHealthAuthInvalid {
+		t.Fatalf("status %s, want %s", got, ServerHealthAuthInvalid)
+	}
+	b.Reset()
+	if !b.Allow() {
+		t.Fatal("reset should allow an immediate retry")
+	}
+	if got := b.Snapshot().Status; got != ServerHealthAuthInvalid {
+		t.Fatalf("reset should keep the last status until the retry completes, got %s", got)
+	}
+}
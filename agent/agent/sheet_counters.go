@@ -0,0 +1,120 @@
+package agent
+
+import (
+	"strconv"
+	"strings"
+
+	"printmaster/common/snmp/oids"
+
+	"github.com/gosnmp/gosnmp"
+)
+
+// SheetCounters holds duplex and N-up usage normalized to sheets of paper,
+// whatever unit the vendor reports. Zero means the device doesn't expose the
+// counter (or it hasn't moved yet).
+type SheetCounters struct {
+	DuplexSheets  int // sheets printed on both sides
+	SimplexSheets int // sheets printed on one side only
+	NUpSheets     int // sheet sides carrying more than one page (2-in-1, 4-in-1, ...)
+}
+
+// DuplexRate returns the share of sheets printed on both sides (0-1). ok is
+// false when the split between simplex and duplex is unknown.
+func (c SheetCounters) DuplexRate() (rate float64, ok bool) {
+	sheets := c.DuplexSheets + c.SimplexSheets
+	if c.DuplexSheets <= 0 || sheets <= 0 {
+		return 0, false
+	}
+	return float64(c.DuplexSheets) / float64(sheets), true
+}
+
+// duplexCounterOIDs are the vendor duplex counters read straight from the
+// PDUs, for vendors without a module that already maps them into meters.
+// perSheet is the number of counts one duplex sheet adds.
+var duplexCounterOIDs = []struct {
+	oid      string
+	perSheet int
+}{
+	{oids.HPDuplexSheets, 1},
+	{oids.CanonDuplexPages, 2},
+	{oids.BrotherDuplexPages, 2},
+}
+
+// NormalizeSheetCounters derives sheet counters from vendor meters and raw
+// PDUs. Meter keys come from vendor modules or learned OIDs:
+//   - duplex_sheets, or duplex_pages / duplex_impressions (two per sheet)
+//   - simplex_sheets or simplex_pages (one per sheet)
+//   - nup_sheets, or per-layout nup_<n>in1 counters which are summed
+//
+// Without a simplex counter it is derived from totalPages, since every
+// duplex sheet carries two of the device's impressions. A duplex count that
+// can't fit in totalPages is dropped rather than skew paper estimates.
+func NormalizeSheetCounters(meters map[string]int, pdus []gosnmp.SnmpPDU, totalPages int) SheetCounters {
+	var c SheetCounters
+
+	c.DuplexSheets = meters["duplex_sheets"]
+	if c.DuplexSheets <= 0 {
+		for _, key := range []string{"duplex_pages", "duplex_impressions"} {
+			if v := meters[key]; v > 0 {
+				c.DuplexSheets = v / 2
+				break
+			}
+		}
+	}
+	if c.DuplexSheets <= 0 {
+		c.DuplexSheets = duplexSheetsFromPDUs(pdus)
+	}
+	if c.DuplexSheets < 0 || (totalPages > 0 && c.DuplexSheets*2 > totalPages) {
+		c.DuplexSheets = 0
+	}
+
+	c.SimplexSheets = meters["simplex_sheets"]
+	if c.SimplexSheets <= 0 {
+		c.SimplexSheets = meters["simplex_pages"]
+	}
+	if c.SimplexSheets <= 0 && c.DuplexSheets > 0 && totalPages > 0 {
+		c.SimplexSheets = totalPages - 2*c.DuplexSheets
+	}
+	if c.SimplexSheets < 0 {
+		c.SimplexSheets = 0
+	}
+
+	c.NUpSheets = meters["nup_sheets"]
+	if c.NUpSheets <= 0 {
+		c.NUpSheets = 0
+		for key, v := range meters {
+			if n, ok := nUpLayout(key); ok && n > 1 && v > 0 {
+				c.NUpSheets += v
+			}
+		}
+	}
+	return c
+}
+
+func duplexSheetsFromPDUs(pdus []gosnmp.SnmpPDU) int {
+	for _, counter := range duplexCounterOIDs {
+		for _, pdu := range pdus {
+			if strings.TrimPrefix(pdu.Name, ".") != counter.oid {
+				continue
+			}
+			if v, ok := toIntValue(pdu.Value); ok && v > 0 {
+				return v / counter.perSheet
+			}
+		}
+	}
+	return 0
+}
+
+// nUpLayout parses meter keys such as "nup_4in1" into their pages per side.
+func nUpLayout(key string) (int, bool) {
+	rest, ok := strings.CutPrefix(key, "nup_")
+	if !ok {
+		return 0, false
+	}
+	rest, ok = strings.CutSuffix(rest, "in1")
+	if !ok {
+		return 0, false
+	}
+	n, err := strconv.Atoi(rest)
+	return n, err == nil
+}
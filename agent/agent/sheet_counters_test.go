@@ -0,0 +1,62 @@
+package agent
+
+import (
+	"testing"
+
+	"printmaster/common/snmp/oids"
+
+	"github.com/gosnmp/gosnmp"
+)
+
+func TestNormalizeSheetCounters(t *testing.T) {
+	t.Parallel()
+	counter := func(oid string, v uint) []gosnmp.SnmpPDU {
+		return []gosnmp.SnmpPDU{{Name: "." + oid, Type: gosnmp.Counter32, Value: v}}
+	}
+
+	tests := []struct {
+		name   string
+		meters map[string]int
+		pdus   []gosnmp.SnmpPDU
+		total  int
+		want   SheetCounters
+	}{
+		{"vendor duplex sheets", map[string]int{"duplex_sheets": 300}, nil, 1000, SheetCounters{DuplexSheets: 300, SimplexSheets: 400}},
+		{"duplex impressions halved", map[string]int{"duplex_pages": 600}, nil, 1000, SheetCounters{DuplexSheets: 300, SimplexSheets: 400}},
+		{"canon counter from PDUs", nil, counter(oids.CanonDuplexPages, 600), 1000, SheetCounters{DuplexSheets: 300, SimplexSheets: 400}},
+		{"reported simplex wins", map[string]int{"duplex_sheets": 300, "simplex_sheets": 350}, nil, 1000, SheetCounters{DuplexSheets: 300, SimplexSheets: 350}},
+		{"duplex larger than total dropped", map[string]int{"duplex_sheets": 800}, nil, 1000, SheetCounters{}},
+		{"n-up layouts summed", map[string]int{"nup_2in1": 40, "nup_4in1": 10, "nup_1in1": 99}, nil, 1000, SheetCounters{NUpSheets: 50}},
+		{"no counters", map[string]int{"total_pages": 1000}, nil, 1000, SheetCounters{}},
+	}
+	for _, tt := range tests {
+		if got := NormalizeSheetCounters(tt.meters, tt.pdus, tt.total); got != tt.want {
+			t.Errorf("%s: got %+v, want %+v", tt.name, got, tt.want)
+		}
+	}
+
+	if rate, ok := (SheetCounters{DuplexSheets: 300, SimplexSheets: 400}).DuplexRate(); !ok || rate < 0.428 || rate > 0.429 {
+		t.Errorf("DuplexRate = %v, %v", rate, ok)
+	}
+	if _, ok := (SheetCounters{NUpSheets: 5}).DuplexRate(); ok {
+		t.Error("DuplexRate should be unknown without a duplex counter")
+	}
+}
+
+func TestMergeLearnedMeters(t *testing.T) {
+	t.Parallel()
+	learned := &LearnedOIDMap{VendorSpecificOIDs: map[string]string{
+		"duplex_pages": "1.3.6.1.4.1.99.1.0",
+		"nup_2in1":     ".1.3.6.1.4.1.99.2.0",
+		"missing":      "1.3.6.1.4.1.99.3.0",
+	}}
+	pdus := []gosnmp.SnmpPDU{
+		{Name: ".1.3.6.1.4.1.99.1.0", Type: gosnmp.Counter32, Value: uint(500)},
+		{Name: ".1.3.6.1.4.1.99.2.0", Type: gosnmp.Counter32, Value: uint(20)},
+	}
+	var pi PrinterInfo
+	MergeLearnedMeters(&pi, learned, pdus)
+	if pi.Meters["duplex_pages"] != 500 || pi.Meters["nup_2in1"] != 20 || len(pi.Meters) != 2 {
+		t.Fatalf("unexpected meters: %+v", pi.Meters)
+	}
+}
@@ -4,6 +4,9 @@ import (
 	"fmt"
 	"time"
 
+	"printmaster/agent/scanner"
+	"printmaster/agent/scanscope"
+
 	"github.com/gosnmp/gosnmp"
 )
 
@@ -16,8 +19,12 @@ type SNMPClient interface {
 }
 
 // NewSNMPClient is a factory used by production code; tests can replace this
-// variable to inject mock clients.
+// variable to inject mock clients. Targets outside the authorized scan scope
+// are refused.
 var NewSNMPClient = func(cfg *SNMPConfig, target string, timeoutSeconds int) (SNMPClient, error) {
+	if err := scanscope.Check(target, "snmp"); err != nil {
+		return nil, err
+	}
 	// Ensure a minimum SNMP timeout to be tolerant of slow devices/networks.
 	tsec := timeoutSeconds
 	if tsec < 30 {
@@ -25,7 +32,7 @@ var NewSNMPClient = func(cfg *SNMPConfig, target string, timeoutSeconds int) (SN
 	}
 	snmp := &gosnmp.GoSNMP{
 		Target:  target,
-		Port:    161,
+		Port:    scanner.SNMPPort(target),
 		Version: cfg.Version,
 		Timeout: time.Duration(tsec) * time.Second,
 		// increase retries to be more tolerant on lossy networks
@@ -57,6 +64,35 @@ var NewSNMPClient = func(cfg *SNMPConfig, target string, timeoutSeconds int) (SN
 	return &gosnmpWrapper{snmp: snmp}, nil
 }
 
+// SNMPSetter writes values to a device with SNMP SET requests.
+type SNMPSetter interface {
+	Set(pdus []gosnmp.SnmpPDU) (*gosnmp.SnmpPacket, error)
+	Close() error
+}
+
+// NewSNMPSetter connects a client for SNMP SET requests. For v1/v2c the write
+// community replaces the read community; v3 uses the configured user, which
+// must have write access on the device. Tests can replace this variable.
+var NewSNMPSetter = func(cfg *SNMPConfig, target, writeCommunity string, timeoutSeconds int) (SNMPSetter, error) {
+	writeCfg := *cfg
+	if writeCfg.Version != gosnmp.Version3 {
+		if writeCommunity == "" {
+			return nil, fmt.Errorf("write community required for SNMP %s", writeCfg.Version)
+		}
+		writeCfg.Community = writeCommunity
+	}
+	client, err := NewSNMPClient(&writeCfg, target, timeoutSeconds)
+	if err != nil {
+		return nil, err
+	}
+	setter, ok := client.(SNMPSetter)
+	if !ok {
+		client.Close()
+		return nil, fmt.Errorf("SNMP client does not support SET")
+	}
+	return setter, nil
+}
+
 // gosnmpWrapper implements SNMPClient by delegating to gosnmp.GoSNMP.
 type gosnmpWrapper struct {
 	snmp *gosnmp.GoSNMP
@@ -77,6 +113,10 @@ func (w *gosnmpWrapper) Walk(root string, walkFn gosnmp.WalkFunc) error {
 	return w.snmp.Walk(root, walkFn)
 }
 
+func (w *gosnmpWrapper) Set(pdus []gosnmp.SnmpPDU) (*gosnmp.SnmpPacket, error) {
+	return w.snmp.Set(pdus)
+}
+
 func (w *gosnmpWrapper) Close() error {
 	if w.snmp != nil && w.snmp.Conn != nil {
 		_ = w.snmp.Conn.Close()
@@ -0,0 +1,208 @@
+package agent
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gosnmp/gosnmp"
+)
+
+// Trap kinds derived from the trap OID and Printer-MIB alert varbinds.
+const (
+	TrapKindSupplyLow    = "supply_low"
+	TrapKindSupplyEmpty  = "supply_empty"
+	TrapKindJam          = "jam"
+	TrapKindCoverOpen    = "cover_open"
+	TrapKindStatusChange = "status_change"
+	TrapKindConfigChange = "config_change"
+	TrapKindWarmingUp    = "warming_up"
+	TrapKindOther        = "other"
+)
+
+// ActionableTrapThrottle is the longest a repeated supply or jam trap for the
+// same supply is ignored.
+const ActionableTrapThrottle = time.Minute
+
+const (
+	snmpTrapOIDVarbind = "1.3.6.1.6.3.1.1.4.1.0" // SNMPv2-MIB::snmpTrapOID.0
+	prtAlertEntry      = "1.3.6.1.2.1.43.18.1.1" // Printer-MIB prtAlertTable entry
+	printerV2Alert     = "1.3.6.1.2.1.43.18.2.0.1"
+)
+
+// Printer-MIB prtAlertGroup values (PrtAlertGroupTC) worth naming.
+var prtAlertGroups = map[int]string{
+	5:  "generalPrinter",
+	6:  "cover",
+	8:  "input",
+	9:  "output",
+	10: "marker",
+	11: "markerSupplies",
+	12: "markerColorant",
+	13: "mediaPath",
+}
+
+// TrapEvent is an SNMP trap decoded into the printer alert it reports.
+// Alert fields are zero when the trap carries no prtAlertTable varbinds.
+type TrapEvent struct {
+	IP          string    `json:"ip"`
+	TrapOID     string    `json:"trap_oid"`
+	Type        string    `json:"type"` // Human-readable trap name
+	Kind        string    `json:"kind"`
+	Severity    string    `json:"severity,omitempty"` // critical or warning
+	Group       string    `json:"group,omitempty"`    // prtAlertGroup name, e.g. markerSupplies
+	GroupIndex  int       `json:"group_index,omitempty"`
+	Code        int       `json:"code,omitempty"` // prtAlertCode
+	Description string    `json:"description,omitempty"`
+	ReceivedAt  time.Time `json:"received_at"`
+}
+
+// Actionable reports whether the trap should trigger targeted collection
+// (supply levels or jam counters) rather than plain rediscovery.
+func (e TrapEvent) Actionable() bool {
+	switch e.Kind {
+	case TrapKindSupplyLow, TrapKindSupplyEmpty, TrapKindJam:
+		return true
+	}
+	return false
+}
+
+// ParseTrap decodes a trap's OID and Printer-MIB alert varbinds. Both SNMPv1
+// traps and SNMPv2c notifications are understood.
+func ParseTrap(packet *gosnmp.SnmpPacket, ip string, receivedAt time.Time) TrapEvent {
+	ev := TrapEvent{IP: ip, ReceivedAt: receivedAt}
+	groupCode := 0
+
+	if packet.PDUType == gosnmp.Trap && packet.GenericTrap == 6 {
+		// SNMPv1 enterprise-specific trap: enterprise.0.specific
+		ev.TrapOID = fmt.Sprintf("%s.0.%d", trimOID(packet.Enterprise), packet.SpecificTrap)
+	}
+
+	for _, pdu := range packet.Variables {
+		name := trimOID(pdu.Name)
+		if name == snmpTrapOIDVarbind {
+			ev.TrapOID = trimOID(pduToString(pdu.Value))
+			continue
+		}
+		if !strings.HasPrefix(name, prtAlertEntry+".") {
+			continue
+		}
+		// prtAlertEntry.<column>.<hrDeviceIndex>.<prtAlertIndex>
+		column, _, _ := strings.Cut(strings.TrimPrefix(name, prtAlertEntry+"."), ".")
+		switch column {
+		case "2":
+			switch trapInt(pdu.Value) {
+			case 3:
+				ev.Severity = "critical"
+			case 4, 5:
+				ev.Severity = "warning"
+			}
+		case "4":
+			groupCode = trapInt(pdu.Value)
+			ev.Group = prtAlertGroups[groupCode]
+			if ev.Group == "" && groupCode > 0 {
+				ev.Group = strconv.Itoa(groupCode)
+			}
+		case "5":
+			ev.GroupIndex = trapInt(pdu.Value)
+		case "7":
+			ev.Code = trapInt(pdu.Value)
+		case "8":
+			ev.Description = strings.TrimSpace(pduToString(pdu.Value))
+		}
+	}
+
+	ev.Kind = classifyTrap(ev.TrapOID, groupCode, ev.Code)
+	ev.Type = trapTypeName(ev.TrapOID, ev.Kind)
+	return ev
+}
+
+// classifyTrap maps a Printer-MIB alert code (PrtAlertCodeTC) to a trap kind,
+// falling back to the trap OID when the trap carries no alert.
+func classifyTrap(trapOID string, group, code int) string {
+	supplyGroup := group == 10 || group == 11 || group == 12
+	switch {
+	case code == 8:
+		return TrapKindJam
+	case code == 3 || code == 5 || code == 501:
+		return TrapKindCoverOpen
+	case code == 7:
+		return TrapKindConfigChange
+	case code == 24:
+		return TrapKindWarmingUp
+	// markerTonerEmpty .. markerTonerCartridgeMissing
+	case code == 1101 || code == 1102 || code == 1103 || code == 1109 || code == 1110 ||
+		code == 1112 || code == 1114 || code == 1115:
+		return TrapKindSupplyEmpty
+	case code >= 1104 && code <= 1113:
+		return TrapKindSupplyLow
+	// Generic subunit codes on marker/supplies groups
+	case supplyGroup && (code == 9 || code == 11 || code == 13 || code == 15 || code == 17):
+		return TrapKindSupplyEmpty
+	case supplyGroup && (code == 10 || code == 12 || code == 14 || code == 16):
+		return TrapKindSupplyLow
+	case code > 0:
+		return TrapKindStatusChange
+	}
+
+	switch trapOID {
+	case printerV2Alert:
+		return TrapKindStatusChange
+	case "1.3.6.1.2.1.43.18.2.0.2":
+		return TrapKindWarmingUp
+	case "1.3.6.1.2.1.43.18.2.0.3":
+		return TrapKindSupplyLow
+	case "1.3.6.1.2.1.43.18.2.0.4":
+		return TrapKindCoverOpen
+	case "1.3.6.1.2.1.43.18.2.0.5":
+		return TrapKindConfigChange
+	}
+	return TrapKindOther
+}
+
+func trapTypeName(trapOID, kind string) string {
+	switch kind {
+	case TrapKindSupplyLow:
+		return "Printer Supply Low"
+	case TrapKindSupplyEmpty:
+		return "Printer Supply Empty"
+	case TrapKindJam:
+		return "Printer Jam"
+	case TrapKindCoverOpen:
+		return "Printer Cover Open"
+	case TrapKindConfigChange:
+		return "Printer Configuration Change"
+	case TrapKindWarmingUp:
+		return "Printer Warming Up"
+	case TrapKindStatusChange:
+		return "Printer Status Change"
+	}
+	if trapOID == "" {
+		return "Generic"
+	}
+	return "Printer Event"
+}
+
+func trimOID(oid string) string {
+	return strings.TrimPrefix(strings.TrimSpace(oid), ".")
+}
+
+func trapInt(v interface{}) int {
+	switch n := v.(type) {
+	case int:
+		return n
+	case int32:
+		return int(n)
+	case int64:
+		return int(n)
+	case uint:
+		return int(n)
+	case uint32:
+		return int(n)
+	case uint64:
+		return int(n)
+	}
+	i, _ := strconv.Atoi(strings.TrimSpace(pduToString(v)))
+	return i
+}
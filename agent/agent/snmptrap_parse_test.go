@@ -0,0 +1,79 @@
+package agent
+
+import (
+	"testing"
+	"time"
+
+	"github.com/gosnmp/gosnmp"
+)
+
+func TestParseTrap_V2PrinterAlert(t *testing.T) {
+	t.Parallel()
+	packet := &gosnmp.SnmpPacket{
+		PDUType: gosnmp.SNMPv2Trap,
+		Variables: []gosnmp.SnmpPDU{
+			{Name: ".1.3.6.1.2.1.1.3.0", Type: gosnmp.TimeTicks, Value: uint32(1234)},
+			{Name: ".1.3.6.1.6.3.1.1.4.1.0", Type: gosnmp.ObjectIdentifier, Value: ".1.3.6.1.2.1.43.18.2.0.1"},
+			{Name: ".1.3.6.1.2.1.43.18.1.1.2.1.7", Type: gosnmp.Integer, Value: 4},
+			{Name: ".1.3.6.1.2.1.43.18.1.1.4.1.7", Type: gosnmp.Integer, Value: 11},
+			{Name: ".1.3.6.1.2.1.43.18.1.1.5.1.7", Type: gosnmp.Integer, Value: 2},
+			{Name: ".1.3.6.1.2.1.43.18.1.1.7.1.7", Type: gosnmp.Integer, Value: 1104},
+			{Name: ".1.3.6.1.2.1.43.18.1.1.8.1.7", Type: gosnmp.OctetString, Value: []byte("Cyan toner low ")},
+		},
+	}
+	ev := ParseTrap(packet, "10.0.0.5", time.Now())
+	if ev.TrapOID != "1.3.6.1.2.1.43.18.2.0.1" {
+		t.Fatalf("trap OID = %q", ev.TrapOID)
+	}
+	if ev.Kind != TrapKindSupplyLow || !ev.Actionable() {
+		t.Fatalf("expected actionable supply_low, got %q", ev.Kind)
+	}
+	if ev.Severity != "warning" || ev.Group != "markerSupplies" || ev.GroupIndex != 2 || ev.Code != 1104 {
+		t.Fatalf("unexpected alert fields: %+v", ev)
+	}
+	if ev.Description != "Cyan toner low" || ev.Type != "Printer Supply Low" {
+		t.Fatalf("unexpected description/type: %q / %q", ev.Description, ev.Type)
+	}
+}
+
+func TestParseTrap_V1EnterpriseTrap(t *testing.T) {
+	t.Parallel()
+	packet := &gosnmp.SnmpPacket{
+		PDUType:  gosnmp.Trap,
+		SnmpTrap: gosnmp.SnmpTrap{Enterprise: ".1.3.6.1.2.1.43.18.2", GenericTrap: 6, SpecificTrap: 1},
+		Variables: []gosnmp.SnmpPDU{
+			{Name: "1.3.6.1.2.1.43.18.1.1.2.1.3", Type: gosnmp.Integer, Value: 3},
+			{Name: "1.3.6.1.2.1.43.18.1.1.4.1.3", Type: gosnmp.Integer, Value: 13},
+			{Name: "1.3.6.1.2.1.43.18.1.1.7.1.3", Type: gosnmp.Integer, Value: 8},
+		},
+	}
+	ev := ParseTrap(packet, "10.0.0.6", time.Now())
+	if ev.TrapOID != "1.3.6.1.2.1.43.18.2.0.1" {
+		t.Fatalf("trap OID = %q", ev.TrapOID)
+	}
+	if ev.Kind != TrapKindJam || ev.Severity != "critical" || ev.Group != "mediaPath" {
+		t.Fatalf("unexpected event: %+v", ev)
+	}
+}
+
+func TestClassifyTrap(t *testing.T) {
+	t.Parallel()
+	cases := []struct {
+		oid         string
+		group, code int
+		want        string
+	}{
+		{"", 0, 1101, TrapKindSupplyEmpty},
+		{"", 11, 10, TrapKindSupplyLow},
+		{"", 11, 9, TrapKindSupplyEmpty},
+		{"", 8, 10, TrapKindStatusChange}, // input tray, not a supply
+		{"", 6, 3, TrapKindCoverOpen},
+		{"1.3.6.1.2.1.43.18.2.0.3", 0, 0, TrapKindSupplyLow},
+		{"1.3.6.1.4.1.11.2.3.9", 0, 0, TrapKindOther},
+	}
+	for _, tc := range cases {
+		if got := classifyTrap(tc.oid, tc.group, tc.code); got != tc.want {
+			t.Errorf("classifyTrap(%q, %d, %d) = %q, want %q", tc.oid, tc.group, tc.code, got, tc.want)
+		}
+	}
+}
@@ -2,31 +2,100 @@ package agent
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"net"
+	"sync"
 	"time"
 
 	"github.com/gosnmp/gosnmp"
 )
 
-// StartSNMPTrapListener listens for SNMP trap notifications on UDP port 162
-// and enqueues discovered devices for SNMP enrichment. Runs until context is canceled.
+const (
+	// DefaultTrapPort is the standard SNMP trap port. It is privileged on
+	// most Unix systems.
+	DefaultTrapPort = 162
+	// DefaultTrapFallbackPort is used when DefaultTrapPort cannot be bound.
+	DefaultTrapFallbackPort = 10162
+
+	trapRetryInitial = 30 * time.Second
+	trapRetryMax     = 5 * time.Minute
+)
+
+// Trap listener states reported by TrapListenerState.
+const (
+	TrapStateStopped   = "stopped"
+	TrapStateStarting  = "starting"
+	TrapStateListening = "listening" // bound to the standard port
+	TrapStateFallback  = "fallback"  // bound to the fallback port; needs a redirect or printer config
+	TrapStateFailed    = "failed"    // no port could be bound; traps are not received
+)
+
+// TrapListenerStatus tells whether trap reception actually works, so the UI
+// can show it instead of the listener failing silently.
+type TrapListenerStatus struct {
+	State          string     `json:"state"`
+	Port           int        `json:"port,omitempty"` // Port actually bound
+	PreferredPort  int        `json:"preferred_port"`
+	FallbackPort   int        `json:"fallback_port"`
+	PrivilegedBind bool       `json:"privileged_bind"` // Process may bind ports below 1024
+	Capability     string     `json:"capability"`      // Why PrivilegedBind is true or false
+	Error          string     `json:"error,omitempty"`
+	Hint           string     `json:"hint,omitempty"` // How to get traps on the standard port
+	TrapsReceived  int64      `json:"traps_received"`
+	LastTrapAt     *time.Time `json:"last_trap_at,omitempty"`
+	Since          time.Time  `json:"since"`
+}
+
+var trapStatus = struct {
+	sync.Mutex
+	s TrapListenerStatus
+}{s: TrapListenerStatus{State: TrapStateStopped, PreferredPort: DefaultTrapPort, FallbackPort: DefaultTrapFallbackPort}}
+
+// TrapListenerState returns the current trap listener status.
+func TrapListenerState() TrapListenerStatus {
+	trapStatus.Lock()
+	defer trapStatus.Unlock()
+	return trapStatus.s
+}
+
+func updateTrapStatus(fn func(s *TrapListenerStatus)) {
+	trapStatus.Lock()
+	defer trapStatus.Unlock()
+	fn(&trapStatus.s)
+}
+
+func setTrapState(state string, port int, err error, hint string) {
+	updateTrapStatus(func(s *TrapListenerStatus) {
+		s.State = state
+		s.Port = port
+		s.Error = ""
+		if err != nil {
+			s.Error = err.Error()
+		}
+		s.Hint = hint
+		s.Since = time.Now()
+	})
+}
+
+// StartSNMPTrapListener listens for SNMP trap notifications on the given UDP
+// port and passes each parsed trap to handle. onListening is called once the
+// socket is bound. Runs until ctx is canceled or the listener fails; a bind
+// failure is returned as a *net.OpError with Op "listen".
 //
-// SNMP traps provide event-driven discovery when printers:
+// SNMP traps provide event-driven discovery and monitoring when printers:
 // - Power on or boot up
 // - Change status (errors, warnings, ready)
 // - Experience supply issues (toner low, paper jam, etc.)
-//
-// Note: Port 162 requires elevated privileges on most systems (admin/root)
-func StartSNMPTrapListener(ctx context.Context, enqueue func(string) bool, port uint16) error {
+func StartSNMPTrapListener(ctx context.Context, handle func(TrapEvent) bool, port uint16, onListening func()) error {
 	if port == 0 {
-		port = 162 // Standard SNMP trap port
+		port = DefaultTrapPort
 	}
 
 	// Create trap listener
 	tl := gosnmp.NewTrapListener()
 	tl.OnNewTrap = func(packet *gosnmp.SnmpPacket, addr *net.UDPAddr) {
-		handleTrap(packet, addr, enqueue)
+		handleTrap(packet, addr, handle)
 	}
 
 	// Set listener parameters
@@ -35,78 +104,117 @@ func StartSNMPTrapListener(ctx context.Context, enqueue func(string) bool, port
 	tl.Params.Community = "public"       // Most printers use "public" for traps
 
 	listenAddr := fmt.Sprintf("0.0.0.0:%d", port)
+	Info(fmt.Sprintf("SNMP Traps: listening on %s", listenAddr))
 
-	Info(fmt.Sprintf("SNMP Traps: listening on %s (requires admin/root privileges)", listenAddr))
+	// Listen blocks until the listener is closed, so run it aside and
+	// close it when ctx ends
+	errCh := make(chan error, 1)
+	go func() { errCh <- tl.Listen(listenAddr) }()
 
-	// Listen on specified port
-	if err := tl.Listen(listenAddr); err != nil {
+	select {
+	case <-tl.Listening():
+	case err := <-errCh:
+		if err == nil {
+			err = errors.New("listener exited before binding")
+		}
 		return fmt.Errorf("failed to start trap listener: %w", err)
+	case <-ctx.Done():
+		tl.Close()
+		return nil
 	}
-	defer tl.Close()
 
 	Info("SNMP Traps: listener started successfully")
+	if onListening != nil {
+		onListening()
+	}
 
-	// Block until context is canceled
-	<-ctx.Done()
-
-	Info("SNMP Traps: stopping listener")
-
-	return nil
+	select {
+	case <-ctx.Done():
+		Info("SNMP Traps: stopping listener")
+		tl.Close()
+		return nil
+	case err := <-errCh:
+		if err == nil {
+			err = errors.New("listener closed")
+		}
+		return fmt.Errorf("trap listener stopped: %w", err)
+	}
 }
 
 // handleTrap processes incoming SNMP trap notifications
-func handleTrap(packet *gosnmp.SnmpPacket, addr *net.UDPAddr, enqueue func(string) bool) {
-	if addr == nil {
+func handleTrap(packet *gosnmp.SnmpPacket, addr *net.UDPAddr, handle func(TrapEvent) bool) {
+	if addr == nil || packet == nil {
 		return
 	}
 
-	ip := addr.IP.String()
-
-	// Log trap reception
-	trapType := "Generic"
-	trapOID := ""
-
-	// Extract trap information from PDUs
-	for _, pdu := range packet.Variables {
-		oidStr := pdu.Name
-
-		// SNMPv2-MIB::snmpTrapOID (identifies the trap type)
-		if oidStr == "1.3.6.1.6.3.1.1.4.1.0" {
-			trapOID = fmt.Sprintf("%v", pdu.Value)
-
-			// Common printer trap OIDs
-			switch trapOID {
-			case "1.3.6.1.2.1.43.18.2.0.1":
-				trapType = "Printer Status Change"
-			case "1.3.6.1.2.1.43.18.2.0.2":
-				trapType = "Printer Warming Up"
-			case "1.3.6.1.2.1.43.18.2.0.3":
-				trapType = "Printer Supply Low"
-			case "1.3.6.1.2.1.43.18.2.0.4":
-				trapType = "Printer Cover Open"
-			case "1.3.6.1.2.1.43.18.2.0.5":
-				trapType = "Printer Configuration Change"
-			default:
-				trapType = "Printer Event"
-			}
-		}
-	}
-
-	Info(fmt.Sprintf("SNMP Trap: received %s from %s (OID: %s)", trapType, ip, trapOID))
+	ev := ParseTrap(packet, addr.IP.String(), time.Now())
+	updateTrapStatus(func(s *TrapListenerStatus) {
+		s.TrapsReceived++
+		at := ev.ReceivedAt
+		s.LastTrapAt = &at
+	})
+	Info(fmt.Sprintf("SNMP Trap: received %s from %s (OID: %s, kind: %s)", ev.Type, ev.IP, ev.TrapOID, ev.Kind))
 
-	// Enqueue device IP for discovery
-	if enqueue(ip) {
-		Info(fmt.Sprintf("SNMP Trap: enqueued %s for discovery", ip))
+	if handle(ev) {
+		Info(fmt.Sprintf("SNMP Trap: handling %s from %s", ev.Kind, ev.IP))
 	}
 }
 
+// isBindError reports whether err means the port could not be bound
+// (missing privileges or already in use).
+func isBindError(err error) bool {
+	var opErr *net.OpError
+	return errors.As(err, &opErr) && opErr.Op == "listen"
+}
+
 // StartSNMPTrapBrowser is a wrapper that handles the trap listener lifecycle
-// with automatic restart on errors and throttling to prevent duplicate discoveries
-func StartSNMPTrapBrowser(ctx context.Context, enqueue func(string) bool, seen map[string]time.Time, throttleWindow time.Duration) {
-	port := uint16(162) // Standard SNMP trap port
+// with automatic restart on errors and throttling to prevent duplicate discoveries.
+// Traps that need follow-up (supply and jam alerts) are throttled per supply
+// or alert kind and for at most ActionableTrapThrottle, so they are not
+// swallowed by an unrelated trap from the same device.
+//
+// The standard port 162 is tried first. When it cannot be bound (no
+// privileges, or another trap receiver holds it) the listener falls back to
+// fallbackPort() and reports how to redirect 162 to it. If neither port can
+// be bound, binding is retried with backoff and the status shows the failure.
+func StartSNMPTrapBrowser(ctx context.Context, handle func(TrapEvent) bool, seen map[string]time.Time, throttleWindow time.Duration, fallbackPort func() int) {
+	privileged, capability := privilegedPortCapability()
+	updateTrapStatus(func(s *TrapListenerStatus) {
+		s.PreferredPort = DefaultTrapPort
+		s.PrivilegedBind = privileged
+		s.Capability = capability
+	})
+	setTrapState(TrapStateStarting, 0, nil, "")
+	Info(fmt.Sprintf("SNMP Trap Browser: privileged port bind %v (%s)", privileged, capability))
+	defer setTrapState(TrapStateStopped, 0, nil, "")
+
+	// Wrap handler with throttling logic
+	var seenMu sync.Mutex
+	throttledHandle := func(ev TrapEvent) bool {
+		now := time.Now()
+		key, window := ev.IP, throttleWindow
+		if ev.Actionable() {
+			key = fmt.Sprintf("%s|%s|%d", ev.IP, ev.Kind, ev.GroupIndex)
+			if window > ActionableTrapThrottle {
+				window = ActionableTrapThrottle
+			}
+		}
+
+		seenMu.Lock()
+		// Check if we've seen this trap recently
+		if lastSeen, exists := seen[key]; exists && now.Sub(lastSeen) < window {
+			seenMu.Unlock()
+			return false // Skip, too soon
+		}
+		// Update last seen time
+		seen[key] = now
+		seenMu.Unlock()
+
+		// Call original handler
+		return handle(ev)
+	}
 
-	// Try to start trap listener
-	// Note: This will fail if not running with elevated privileges
+	retry := trapRetryInitial
 	for {
 		select {
 		case <-ctx.Done():
@@ -115,37 +223,35 @@ func StartSNMPTrapBrowser(ctx context.Context, enqueue func(string) bool, seen m
 		default:
 		}
 
-		// Wrap enqueue with throttling logic
-		throttledEnqueue := func(ip string) bool {
-			now := time.Now()
-
-			// Check if we've seen this IP recently
-			if lastSeen, exists := seen[ip]; exists {
-				if now.Sub(lastSeen) < throttleWindow {
-					return false // Skip, too soon
-				}
+		fallback := DefaultTrapFallbackPort
+		if fallbackPort != nil {
+			if p := fallbackPort(); p > 0 && p <= 65535 {
+				fallback = p
 			}
-
-			// Update last seen time
-			seen[ip] = now
-
-			// Call original enqueue
-			return enqueue(ip)
 		}
+		updateTrapStatus(func(s *TrapListenerStatus) { s.FallbackPort = fallback })
 
-		// Start trap listener (blocking)
-		err := StartSNMPTrapListener(ctx, throttledEnqueue, port)
-
+		// Start trap listener on the standard port (blocking)
+		err := StartSNMPTrapListener(ctx, throttledHandle, DefaultTrapPort, func() {
+			retry = trapRetryInitial
+			setTrapState(TrapStateListening, DefaultTrapPort, nil, "")
+		})
+		if err != nil && isBindError(err) && fallback != DefaultTrapPort {
+			primaryErr := err
+			Info(fmt.Sprintf("SNMP Trap Browser: cannot bind port %d (%v); falling back to port %d", DefaultTrapPort, err, fallback))
+			hint := trapRedirectHint(fallback)
+			err = StartSNMPTrapListener(ctx, throttledHandle, uint16(fallback), func() {
+				retry = trapRetryInitial
+				setTrapState(TrapStateFallback, fallback, primaryErr, hint)
+			})
+			if err != nil && isBindError(err) {
+				setTrapState(TrapStateFailed, 0, fmt.Errorf("port %d: %v; fallback port %d: %v", DefaultTrapPort, primaryErr, fallback, err), hint)
+			}
+		}
 		if err != nil {
 			Info("SNMP Trap Browser: " + err.Error())
-
-			// Check if it's a permission error
-			if netErr, ok := err.(*net.OpError); ok {
-				if netErr.Op == "listen" {
-					Info("SNMP Trap Browser: Port 162 requires administrator/root privileges")
-					Info("SNMP Trap Browser: Run as admin or disable trap monitoring")
-					return // Don't retry if it's a permission issue
-				}
+			if !isBindError(err) {
+				setTrapState(TrapStateFailed, 0, err, "")
 			}
 		}
 
@@ -156,8 +262,16 @@ func StartSNMPTrapBrowser(ctx context.Context, enqueue func(string) bool, seen m
 		default:
 		}
 
-		// Otherwise, wait a bit before retrying
-		Info("SNMP Trap Browser: restarting in 30 seconds...")
-		time.Sleep(30 * time.Second)
+		// Otherwise, wait before retrying; bind failures back off since the
+		// port rarely frees up quickly
+		Info(fmt.Sprintf("SNMP Trap Browser: restarting in %s...", retry))
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(retry):
+		}
+		if retry *= 2; retry > trapRetryMax {
+			retry = trapRetryMax
+		}
 	}
 }
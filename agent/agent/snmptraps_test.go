@@ -0,0 +1,95 @@
+package agent
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+)
+
+// holdUDP binds a UDP port so the trap listener cannot; port 0 picks a
+// free one.
+func holdUDP(t *testing.T, port int) *net.UDPConn {
+	t.Helper()
+	conn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4zero, Port: port})
+	if err != nil {
+		return nil
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+func waitTrapState(t *testing.T, want string) TrapListenerStatus {
+	t.Helper()
+	deadline := time.Now().Add(3 * time.Second)
+	for {
+		st := TrapListenerState()
+		if st.State == want {
+			return st
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("trap listener state = %q (%s), want %q", st.State, st.Error, want)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestSNMPTrapBrowserFallsBackWhenPortUnavailable(t *testing.T) {
+	// Either this test holds 162 or the process lacks the privilege to bind
+	// it; both force the fallback
+	holdUDP(t, DefaultTrapPort)
+	probe := holdUDP(t, 0)
+	if probe == nil {
+		t.Skip("cannot bind UDP sockets")
+	}
+	fallback := probe.LocalAddr().(*net.UDPAddr).Port
+	probe.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		StartSNMPTrapBrowser(ctx, func(TrapEvent) bool { return true }, map[string]time.Time{}, time.Minute, func() int { return fallback })
+		close(done)
+	}()
+
+	st := waitTrapState(t, TrapStateFallback)
+	if st.Port != fallback || st.FallbackPort != fallback || st.Hint == "" || st.Error == "" || st.Capability == "" {
+		t.Errorf("fallback status = %+v", st)
+	}
+	// The fallback port is really bound
+	if conn := holdUDP(t, fallback); conn != nil {
+		t.Error("fallback port is not held by the listener")
+	}
+
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(3 * time.Second):
+		t.Fatal("trap browser did not stop on cancel")
+	}
+	if st := TrapListenerState(); st.State != TrapStateStopped {
+		t.Errorf("state after stop = %q", st.State)
+	}
+	// Cancel closes the socket
+	if conn := holdUDP(t, fallback); conn == nil {
+		t.Error("fallback port still bound after stop")
+	}
+}
+
+func TestSNMPTrapBrowserReportsFailure(t *testing.T) {
+	holdUDP(t, DefaultTrapPort)
+	blocker := holdUDP(t, 0)
+	if blocker == nil {
+		t.Skip("cannot bind UDP sockets")
+	}
+	fallback := blocker.LocalAddr().(*net.UDPAddr).Port
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go StartSNMPTrapBrowser(ctx, func(TrapEvent) bool { return true }, map[string]time.Time{}, time.Minute, func() int { return fallback })
+
+	st := waitTrapState(t, TrapStateFailed)
+	if st.Port != 0 || st.Error == "" || st.Hint == "" {
+		t.Errorf("failed status = %+v", st)
+	}
+}
@@ -0,0 +1,64 @@
+//go:build linux
+// +build linux
+
+package agent
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// capNetBindService is the capability bit that allows binding ports below
+// ip_unprivileged_port_start.
+const capNetBindService = 10
+
+// privilegedPortCapability reports whether this process can bind the
+// standard trap port, and why.
+func privilegedPortCapability() (bool, string) {
+	if os.Geteuid() == 0 {
+		return true, "running as root"
+	}
+	if b, err := os.ReadFile("/proc/sys/net/ipv4/ip_unprivileged_port_start"); err == nil {
+		if start, err := strconv.Atoi(strings.TrimSpace(string(b))); err == nil && start <= DefaultTrapPort {
+			return true, fmt.Sprintf("net.ipv4.ip_unprivileged_port_start is %d", start)
+		}
+	}
+	if hasEffectiveCapability(capNetBindService) {
+		return true, "CAP_NET_BIND_SERVICE granted"
+	}
+	return false, "not root and CAP_NET_BIND_SERVICE not granted"
+}
+
+// hasEffectiveCapability reads CapEff from /proc/self/status.
+func hasEffectiveCapability(bit uint) bool {
+	f, err := os.Open("/proc/self/status")
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if !strings.HasPrefix(line, "CapEff:") {
+			continue
+		}
+		caps, err := strconv.ParseUint(strings.TrimSpace(strings.TrimPrefix(line, "CapEff:")), 16, 64)
+		return err == nil && caps&(1<<bit) != 0
+	}
+	return false
+}
+
+// trapRedirectHint explains how to deliver traps sent to 162 to the
+// fallback port.
+func trapRedirectHint(fallbackPort int) string {
+	return fmt.Sprintf("Traps sent to UDP %d are not received. Either grant the agent binary the capability "+
+		"(setcap 'cap_net_bind_service=+ep' /path/to/printmaster-agent, or AmbientCapabilities=CAP_NET_BIND_SERVICE "+
+		"in the systemd unit) and restart it, or redirect the port on this host: "+
+		"iptables -t nat -A PREROUTING -p udp --dport %d -j REDIRECT --to-ports %d "+
+		"(nftables: add rule ip nat prerouting udp dport %d redirect to :%d), "+
+		"or configure printers to send traps to port %d.",
+		DefaultTrapPort, DefaultTrapPort, fallbackPort, DefaultTrapPort, fallbackPort, fallbackPort)
+}
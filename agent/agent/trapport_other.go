@@ -0,0 +1,45 @@
+//go:build !linux
+// +build !linux
+
+package agent
+
+import (
+	"fmt"
+	"os"
+	"runtime"
+)
+
+// privilegedPortCapability reports whether this process can bind the
+// standard trap port, and why.
+func privilegedPortCapability() (bool, string) {
+	switch runtime.GOOS {
+	case "windows":
+		return true, "Windows does not restrict low ports"
+	case "darwin":
+		return true, "macOS allows binding low ports on all interfaces"
+	}
+	if os.Geteuid() == 0 {
+		return true, "running as root"
+	}
+	return false, "not running as root"
+}
+
+// trapRedirectHint explains how to deliver traps sent to 162 to the
+// fallback port.
+func trapRedirectHint(fallbackPort int) string {
+	switch runtime.GOOS {
+	case "windows":
+		return fmt.Sprintf("Another program holds UDP %d, usually the Windows \"SNMP Trap\" service (snmptrap). "+
+			"Stop and disable it, then re-enable the listener, or configure printers to send traps to port %d.",
+			DefaultTrapPort, fallbackPort)
+	case "darwin":
+		return fmt.Sprintf("Another program holds UDP %d. Stop it, or redirect the port with pf "+
+			"(rdr pass on en0 inet proto udp from any to any port %d -> 127.0.0.1 port %d), "+
+			"or configure printers to send traps to port %d.",
+			DefaultTrapPort, DefaultTrapPort, fallbackPort, fallbackPort)
+	}
+	return fmt.Sprintf("Traps sent to UDP %d are not received. Run the agent as root, redirect the port with the "+
+		"host firewall (pf: rdr pass inet proto udp from any to any port %d -> 127.0.0.1 port %d), "+
+		"or configure printers to send traps to port %d.",
+		DefaultTrapPort, DefaultTrapPort, fallbackPort, fallbackPort)
+}
@@ -3,6 +3,8 @@ package agent
 import (
 	"context"
 	"time"
+
+	"printmaster/agent/supplies"
 )
 
 // PaperTray represents the status of a single paper input tray
@@ -84,12 +86,22 @@ type PrinterInfo struct {
 	ColorImpressions int `json:"color_impressions,omitempty"`
 	// TonerLevels maps a supply description to its reported level (where available)
 	TonerLevels map[string]int `json:"toner_levels,omitempty"`
+	// SupplyReadings keeps the raw level, max capacity and unit behind each
+	// TonerLevels entry, keyed the same way
+	SupplyReadings map[string]supplies.Reading `json:"supply_readings,omitempty"`
 	// Consumables lists supply descriptions discovered on the device
 	Consumables []string `json:"consumables,omitempty"`
 	// StatusMessages contains textual status lines reported via SNMP (if any)
 	StatusMessages []string `json:"status_messages,omitempty"`
 	// Firmware reported by device (if present)
 	Firmware string `json:"firmware,omitempty"`
+	// SysObjectID is the SNMP sysObjectID (1.3.6.1.2.1.1.2.0) reported by the device
+	SysObjectID string `json:"sys_object_id,omitempty"`
+	// SNMPVersion is the SNMP protocol version the device answered on ("1", "2c", "3")
+	SNMPVersion string `json:"snmp_version,omitempty"`
+	// SNMPDefaultCommunity is set when the device accepted a well-known default
+	// community string such as "public" or "private"
+	SNMPDefaultCommunity bool `json:"snmp_default_community,omitempty"`
 	// UptimeSeconds (if reported via sysUpTime or similar)
 	UptimeSeconds int `json:"uptime_seconds,omitempty"`
 	// DuplexSupported indicates whether the device advertises duplex capability
@@ -114,6 +126,10 @@ type PrinterInfo struct {
 	// WebUIURL is the detected HTTP/HTTPS URL to the device's web interface (if available)
 	WebUIURL string `json:"web_ui_url,omitempty"`
 
+	// WebFingerprint holds the HTTP Server banner, page title and TLS certificate
+	// details captured while probing the web interface (if available)
+	WebFingerprint *WebFingerprint `json:"web_fingerprint,omitempty"`
+
 	// LearnedOIDs stores device-specific OID mappings discovered during initial walk
 	// This allows metrics collection to use known-working OIDs instead of generic queries
 	LearnedOIDs LearnedOIDMap `json:"learned_oids,omitempty"`
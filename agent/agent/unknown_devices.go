@@ -0,0 +1,164 @@
+package agent
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// UnknownDevice is an anonymized sample of a printer whose manufacturer could
+// not be identified. It carries only what vendor detection works from: the
+// sysObjectID, the sysDescr with addresses and the serial scrubbed, and the
+// model string. No IP, MAC, serial or hostname leaves the agent.
+type UnknownDevice struct {
+	SysObjectID string    `json:"sys_object_id"`
+	SysDescr    string    `json:"sys_descr"`
+	Enterprise  string    `json:"enterprise,omitempty"`
+	Model       string    `json:"model,omitempty"`
+	Count       int       `json:"count"`
+	FirstSeen   time.Time `json:"first_seen"`
+	LastSeen    time.Time `json:"last_seen"`
+}
+
+// maxUnknownDevices bounds the in-memory catalog; new kinds past the limit
+// are still written to the local log.
+const maxUnknownDevices = 500
+
+// maxUnknownSysDescrLen caps the sysDescr kept per sample.
+const maxUnknownSysDescrLen = 255
+
+var (
+	unknownMu       sync.Mutex
+	unknownDevices  = map[string]*UnknownDevice{}
+	unknownReported = map[string]int{} // key -> Count at last report
+
+	ipv4Re = regexp.MustCompile(`\b\d{1,3}(?:\.\d{1,3}){3}\b`)
+	macRe  = regexp.MustCompile(`(?i)\b[0-9a-f]{2}(?:[:-][0-9a-f]{2}){5}\b`)
+)
+
+// AnonymizeSysDescr removes addresses and the device serial from a sysDescr
+// so it can be shared outside the site.
+func AnonymizeSysDescr(sysDescr, serial string) string {
+	s := strings.TrimSpace(sysDescr)
+	s = macRe.ReplaceAllString(s, "<mac>")
+	s = ipv4Re.ReplaceAllString(s, "<ip>")
+	if serial = strings.TrimSpace(serial); len(serial) >= 4 {
+		s = strings.ReplaceAll(s, serial, "<serial>")
+	}
+	if len(s) > maxUnknownSysDescrLen {
+		s = s[:maxUnknownSysDescrLen]
+	}
+	return s
+}
+
+// RecordUnknownDevice notes a printer the parser could not attribute to a
+// manufacturer. The first sighting of each sysObjectID/sysDescr pair is
+// appended to logs/unknown_mfg.log; all sightings are counted for the
+// optional unknown-device telemetry.
+func RecordUnknownDevice(ip, serial, sysObjectID, sysDescr, model string) {
+	sysObjectID = strings.TrimPrefix(strings.TrimSpace(sysObjectID), ".")
+	desc := AnonymizeSysDescr(sysDescr, serial)
+	if sysObjectID == "" && desc == "" {
+		return
+	}
+	key := sysObjectID + "|" + desc
+	now := time.Now().UTC()
+
+	unknownMu.Lock()
+	entry, seen := unknownDevices[key]
+	if seen {
+		entry.Count++
+		entry.LastSeen = now
+		if entry.Model == "" {
+			entry.Model = strings.TrimSpace(model)
+		}
+	} else if len(unknownDevices) < maxUnknownDevices {
+		unknownDevices[key] = &UnknownDevice{
+			SysObjectID: sysObjectID,
+			SysDescr:    desc,
+			Enterprise:  enterpriseNumber(sysObjectID),
+			Model:       strings.TrimSpace(model),
+			Count:       1,
+			FirstSeen:   now,
+			LastSeen:    now,
+		}
+	}
+	unknownMu.Unlock()
+
+	if !seen {
+		line := fmt.Sprintf("%s | %s | sysObjectID: %s | sysDescr: %q\n", now.Format(time.RFC3339), ip, sysObjectID, strings.TrimSpace(sysDescr))
+		fpath := filepath.Join(ensureLogDir(), "unknown_mfg.log")
+		if f, err := os.OpenFile(fpath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644); err == nil {
+			_, _ = f.WriteString(line)
+			f.Close()
+		}
+	}
+}
+
+// UnknownDevices returns the catalog of unknown devices seen since startup,
+// most frequent first.
+func UnknownDevices() []UnknownDevice {
+	unknownMu.Lock()
+	out := make([]UnknownDevice, 0, len(unknownDevices))
+	for _, d := range unknownDevices {
+		out = append(out, *d)
+	}
+	unknownMu.Unlock()
+	sortUnknownDevices(out)
+	return out
+}
+
+// PendingUnknownDevices returns entries with sightings not yet reported.
+func PendingUnknownDevices() []UnknownDevice {
+	unknownMu.Lock()
+	var out []UnknownDevice
+	for key, d := range unknownDevices {
+		if unknownReported[key] != d.Count {
+			out = append(out, *d)
+		}
+	}
+	unknownMu.Unlock()
+	sortUnknownDevices(out)
+	return out
+}
+
+// MarkUnknownDevicesReported records that the given entries were uploaded, so
+// they are only sent again once seen more often.
+func MarkUnknownDevicesReported(entries []UnknownDevice) {
+	unknownMu.Lock()
+	for _, d := range entries {
+		unknownReported[d.SysObjectID+"|"+d.SysDescr] = d.Count
+	}
+	unknownMu.Unlock()
+}
+
+// ResetUnknownDevices clears the in-memory catalog (for tests).
+func ResetUnknownDevices() {
+	unknownMu.Lock()
+	unknownDevices = map[string]*UnknownDevice{}
+	unknownReported = map[string]int{}
+	unknownMu.Unlock()
+}
+
+func sortUnknownDevices(list []UnknownDevice) {
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].Count != list[j].Count {
+			return list[i].Count > list[j].Count
+		}
+		return list[i].SysObjectID < list[j].SysObjectID
+	})
+}
+
+// enterpriseNumber returns the enterprise number of a 1.3.6.1.4.1.<n> OID.
+func enterpriseNumber(oid string) string {
+	parts := strings.Split(oid, ".")
+	if len(parts) >= 7 && strings.Join(parts[:6], ".") == "1.3.6.1.4.1" {
+		return parts[6]
+	}
+	return ""
+}
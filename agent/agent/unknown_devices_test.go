@@ -0,0 +1,69 @@
+package agent
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/gosnmp/gosnmp"
+)
+
+func TestAnonymizeSysDescr(t *testing.T) {
+	got := AnonymizeSysDescr("Acme MFP 3000 SN:XK12345 IP 10.1.2.3 MAC 00:1B:44:11:3A:B7", "XK12345")
+	want := "Acme MFP 3000 SN:<serial> IP <ip> MAC <mac>"
+	if got != want {
+		t.Fatalf("AnonymizeSysDescr = %q, want %q", got, want)
+	}
+	if long := AnonymizeSysDescr(strings.Repeat("x", 400), ""); len(long) != maxUnknownSysDescrLen {
+		t.Fatalf("expected sysDescr capped at %d, got %d", maxUnknownSysDescrLen, len(long))
+	}
+}
+
+func TestParsePDUsRecordsUnknownDevice(t *testing.T) {
+	ResetUnknownDevices()
+	t.Cleanup(ResetUnknownDevices)
+
+	vars := []gosnmp.SnmpPDU{
+		{Name: "1.3.6.1.2.1.1.2.0", Type: gosnmp.ObjectIdentifier, Value: ".1.3.6.1.4.1.55555.1.2"},
+		{Name: "1.3.6.1.2.1.1.1.0", Type: gosnmp.OctetString, Value: []byte("Acme MFP 3000 at 10.9.9.9")},
+		{Name: "1.3.6.1.2.1.43.10.2.1.4.1.1", Type: gosnmp.Counter32, Value: uint(1234)},
+	}
+	for i := 0; i < 2; i++ {
+		if _, ok := ParsePDUs("10.9.9.9", vars, nil, nil); !ok {
+			t.Fatalf("expected device to be detected as printer")
+		}
+	}
+
+	var entry *UnknownDevice
+	for _, d := range PendingUnknownDevices() {
+		if d.SysObjectID == "1.3.6.1.4.1.55555.1.2" {
+			d := d
+			entry = &d
+		}
+	}
+	if entry == nil {
+		t.Fatalf("expected unknown device to be recorded, got %+v", UnknownDevices())
+	}
+	if entry.Enterprise != "55555" || entry.Count != 2 || entry.SysDescr != "Acme MFP 3000 at <ip>" {
+		t.Fatalf("unexpected entry: %+v", entry)
+	}
+
+	MarkUnknownDevicesReported([]UnknownDevice{*entry})
+	for _, d := range PendingUnknownDevices() {
+		if d.SysObjectID == entry.SysObjectID {
+			t.Fatalf("reported entry still pending")
+		}
+	}
+	ParsePDUs("10.9.9.9", vars, nil, nil)
+	pending := PendingUnknownDevices()
+	if len(pending) != 1 || pending[0].Count != 3 {
+		t.Fatalf("expected entry pending again after a new sighting, got %+v", pending)
+	}
+
+	// Recognized vendors are not recorded
+	ResetUnknownDevices()
+	vars[0].Value = ".1.3.6.1.4.1.11.2.3.9.1"
+	ParsePDUs("10.9.9.10", vars, nil, nil)
+	if got := UnknownDevices(); len(got) != 0 {
+		t.Fatalf("expected no unknown devices for HP, got %+v", got)
+	}
+}
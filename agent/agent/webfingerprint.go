@@ -0,0 +1,106 @@
+package agent
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"html"
+	"io"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// WebFingerprint captures identifying details from a device's embedded web
+// server. The TLS certificate subject and HTTP Server banner frequently name
+// the exact model or firmware when SNMP sysDescr is generic, and a changed
+// certificate fingerprint on a known device can indicate a spoofed host.
+type WebFingerprint struct {
+	URL            string    `json:"url,omitempty"`
+	StatusCode     int       `json:"status_code,omitempty"`
+	Server         string    `json:"server,omitempty"`      // HTTP Server header
+	PoweredBy      string    `json:"powered_by,omitempty"`  // HTTP X-Powered-By header
+	Title          string    `json:"title,omitempty"`       // HTML <title> of the landing page
+	TLSVersion     string    `json:"tls_version,omitempty"` // Negotiated TLS version (HTTPS only)
+	CertSubject    string    `json:"cert_subject,omitempty"`
+	CertIssuer     string    `json:"cert_issuer,omitempty"`
+	CertSANs       []string  `json:"cert_sans,omitempty"`
+	CertSerial     string    `json:"cert_serial,omitempty"`
+	CertSHA256     string    `json:"cert_sha256,omitempty"`
+	CertNotBefore  time.Time `json:"cert_not_before,omitzero"`
+	CertNotAfter   time.Time `json:"cert_not_after,omitzero"`
+	CertSelfSigned bool      `json:"cert_self_signed,omitempty"`
+}
+
+// HasTLS reports whether certificate details were captured.
+func (f *WebFingerprint) HasTLS() bool {
+	return f != nil && f.CertSHA256 != ""
+}
+
+// IsEmpty reports whether nothing useful was captured.
+func (f *WebFingerprint) IsEmpty() bool {
+	return f == nil || (f.Server == "" && f.PoweredBy == "" && f.Title == "" && !f.HasTLS())
+}
+
+// maxFingerprintBody limits how much of the landing page is read to find the title.
+const maxFingerprintBody = 64 * 1024
+
+var htmlTitleRegex = regexp.MustCompile(`(?is)<title[^>]*>(.*?)</title>`)
+
+// fingerprintFromResponse extracts banner and certificate details from an HTTP
+// response. The body is read (bounded) to capture the page title.
+func fingerprintFromResponse(resp *http.Response) *WebFingerprint {
+	if resp == nil {
+		return nil
+	}
+	fp := &WebFingerprint{
+		StatusCode: resp.StatusCode,
+		Server:     strings.TrimSpace(resp.Header.Get("Server")),
+		PoweredBy:  strings.TrimSpace(resp.Header.Get("X-Powered-By")),
+	}
+	if resp.Request != nil && resp.Request.URL != nil {
+		fp.URL = resp.Request.URL.String()
+	}
+	if resp.Body != nil {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, maxFingerprintBody))
+		fp.Title = extractHTMLTitle(body)
+	}
+	if resp.TLS != nil {
+		fp.TLSVersion = tls.VersionName(resp.TLS.Version)
+		if len(resp.TLS.PeerCertificates) > 0 {
+			applyCertificate(fp, resp.TLS.PeerCertificates[0])
+		}
+	}
+	return fp
+}
+
+// applyCertificate copies identifying fields of the leaf certificate into fp.
+func applyCertificate(fp *WebFingerprint, cert *x509.Certificate) {
+	sum := sha256.Sum256(cert.Raw)
+	fp.CertSHA256 = hex.EncodeToString(sum[:])
+	fp.CertSubject = cert.Subject.String()
+	fp.CertIssuer = cert.Issuer.String()
+	fp.CertNotBefore = cert.NotBefore.UTC()
+	fp.CertNotAfter = cert.NotAfter.UTC()
+	if cert.SerialNumber != nil {
+		fp.CertSerial = cert.SerialNumber.Text(16)
+	}
+	fp.CertSANs = append(fp.CertSANs, cert.DNSNames...)
+	for _, ip := range cert.IPAddresses {
+		fp.CertSANs = append(fp.CertSANs, ip.String())
+	}
+	fp.CertSelfSigned = bytes.Equal(cert.RawSubject, cert.RawIssuer) && cert.CheckSignatureFrom(cert) == nil
+}
+
+// extractHTMLTitle returns the whitespace-normalized page title, if any.
+func extractHTMLTitle(body []byte) string {
+	m := htmlTitleRegex.FindSubmatch(body)
+	if m == nil {
+		return ""
+	}
+	title := html.UnescapeString(string(m[1]))
+	return strings.Join(strings.Fields(title), " ")
+}
@@ -0,0 +1,67 @@
+package agent
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestProbeWebUI_CapturesFingerprint(t *testing.T) {
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Server", "HP HTTP Server; HP Color LaserJet MFP M477fdw - CF379A; Serial Number: ABC123")
+		w.Write([]byte("<html><head><title>\n  HP Color LaserJet &amp; MFP\n</title></head></html>"))
+	}))
+	defer srv.Close()
+
+	finalURL, fp := probeWebUI(srv.URL)
+	if finalURL == "" {
+		t.Fatal("expected probe to succeed")
+	}
+	if fp == nil {
+		t.Fatal("expected fingerprint")
+	}
+	if !strings.Contains(fp.Server, "M477fdw") {
+		t.Errorf("Server = %q, want banner with model", fp.Server)
+	}
+	if fp.Title != "HP Color LaserJet & MFP" {
+		t.Errorf("Title = %q", fp.Title)
+	}
+	if !fp.HasTLS() || len(fp.CertSHA256) != 64 {
+		t.Fatalf("expected TLS certificate fingerprint, got %+v", fp)
+	}
+	if fp.CertSubject == "" || fp.CertIssuer == "" || fp.TLSVersion == "" {
+		t.Errorf("missing certificate details: %+v", fp)
+	}
+	if fp.CertNotAfter.IsZero() {
+		t.Error("expected certificate expiry")
+	}
+}
+
+func TestProbeWebUI_PlainHTTPHasNoCertificate(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Server", "KM-MFP-http/V0.0.1")
+		w.WriteHeader(http.StatusUnauthorized)
+	}))
+	defer srv.Close()
+
+	finalURL, fp := probeWebUI(srv.URL)
+	if finalURL == "" || fp == nil {
+		t.Fatal("expected 401 response to count as a web UI")
+	}
+	if fp.HasTLS() {
+		t.Error("plain HTTP should not report a certificate")
+	}
+	if fp.Server != "KM-MFP-http/V0.0.1" || fp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("unexpected fingerprint: %+v", fp)
+	}
+
+	data, err := json.Marshal(fp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(data), "cert_not_after") {
+		t.Errorf("zero certificate dates should be omitted: %s", data)
+	}
+}
@@ -16,6 +16,7 @@ import (
 	"sync"
 	"time"
 
+	"printmaster/agent/featureflags"
 	wscommon "printmaster/common/ws"
 )
 
@@ -58,6 +59,12 @@ type WSClient struct {
 	maxReconnectDelay  time.Duration
 	insecureSkipVerify bool
 
+	// Called when a heartbeat pong carries a rotated agent token
+	tokenHandler func(token string, expiresAt time.Time)
+
+	// Called when a heartbeat pong lists the relays offered by the server
+	relayHandler func([]RelayEndpoint)
+
 	// Local handler for direct invocation (avoids localhost HTTP round-trip)
 	localHandler      http.Handler
 	localHandlerMu    sync.RWMutex
@@ -87,6 +94,30 @@ func NewWSClient(serverURL, token string, insecureSkipVerify bool) *WSClient {
 	}
 }
 
+// SetToken changes the token used for future connections. The current
+// connection stays open; the server already authenticated it.
+func (ws *WSClient) SetToken(token string) {
+	ws.mu.Lock()
+	defer ws.mu.Unlock()
+	ws.token = token
+}
+
+// SetTokenHandler registers a callback for rotated tokens delivered in
+// heartbeat responses. The handler must persist the token before adopting it.
+func (ws *WSClient) SetTokenHandler(handler func(token string, expiresAt time.Time)) {
+	ws.mu.Lock()
+	defer ws.mu.Unlock()
+	ws.tokenHandler = handler
+}
+
+// SetRelayHandler registers a callback for the relays offered in heartbeat
+// responses.
+func (ws *WSClient) SetRelayHandler(handler func([]RelayEndpoint)) {
+	ws.mu.Lock()
+	defer ws.mu.Unlock()
+	ws.relayHandler = handler
+}
+
 // SetLocalHandler sets the local HTTP handler for direct invocation.
 // When proxy requests target localhost:8080, the handler is invoked directly
 // instead of making an HTTP round-trip. This avoids SSRF validation issues
@@ -251,7 +282,7 @@ func (ws *WSClient) connect() error {
 	skipVerify := ws.insecureSkipVerify
 
 	// Connect using shared ws wrapper
-	conn, resp, err := wscommon.Dial(u.String(), nil, &tls.Config{InsecureSkipVerify: skipVerify}, ws.handshakeTimeout)
+	conn, resp, err := wscommon.DialWithProxy(u.String(), nil, &tls.Config{InsecureSkipVerify: skipVerify}, ws.handshakeTimeout, ProxyForServer)
 	if err != nil {
 		// Try to include HTTP response body and headers from the upgrade attempt for easier debugging
 		if resp != nil {
@@ -413,6 +444,8 @@ func (ws *WSClient) readLoop() {
 			switch msg.Type {
 			case wscommon.MessageTypePong:
 				// Pong received, connection is healthy
+				ws.handleTokenRenewal(msg.Data)
+				ws.handleRelays(msg.Data)
 			case wscommon.MessageTypeError:
 				WarnCtx("Server error", "data", msg.Data)
 			case wscommon.MessageTypeCommand:
@@ -474,6 +507,45 @@ func (ws *WSClient) pingLoop() {
 	}
 }
 
+// handleTokenRenewal passes a rotated token from a heartbeat pong to the
+// registered handler.
+func (ws *WSClient) handleTokenRenewal(data map[string]interface{}) {
+	token, _ := data["agent_token"].(string)
+	if token == "" {
+		return
+	}
+	ws.mu.RLock()
+	handler := ws.tokenHandler
+	ws.mu.RUnlock()
+	if handler == nil {
+		return
+	}
+	var expiresAt time.Time
+	if raw, ok := data["agent_token_expires_at"].(string); ok {
+		expiresAt, _ = time.Parse(time.RFC3339, raw)
+	}
+	handler(token, expiresAt)
+}
+
+// handleRelays passes the relays listed in a heartbeat pong to the
+// registered handler. A pong without relays means none are offered.
+func (ws *WSClient) handleRelays(data map[string]interface{}) {
+	ws.mu.RLock()
+	handler := ws.relayHandler
+	ws.mu.RUnlock()
+	if handler == nil {
+		return
+	}
+	var relays []RelayEndpoint
+	if raw, ok := data["relays"]; ok {
+		encoded, err := json.Marshal(raw)
+		if err == nil {
+			_ = json.Unmarshal(encoded, &relays)
+		}
+	}
+	handler(relays)
+}
+
 // SendHeartbeat sends a heartbeat message over the WebSocket
 func (ws *WSClient) SendHeartbeat(data map[string]interface{}) error {
 	ws.mu.RLock()
@@ -550,6 +622,12 @@ func (ws *WSClient) handleProxyRequest(msg wscommon.Message) {
 		return
 	}
 
+	if !featureflags.RemoteProxyEnabled() {
+		WarnCtx("Proxy request rejected: remote proxy disabled by server", "request_id", requestID)
+		ws.sendProxyError(requestID, "Remote proxy is disabled for this agent")
+		return
+	}
+
 	targetURL, ok := msg.Data["url"].(string)
 	if !ok {
 		ws.sendProxyError(requestID, "Missing target URL")
@@ -975,14 +1053,18 @@ func (ws *WSClient) handleCommand(msg wscommon.Message) {
 	}
 
 	InfoCtx("Received command from server", "command", command)
+	dispatchCommand(command, msg.Data)
+}
 
-	// Call registered handler if present
+// dispatchCommand passes a server command to the registered handler. Both
+// the WebSocket and MQTT transports deliver commands through it.
+func dispatchCommand(command string, data map[string]interface{}) {
 	commandHandlerMu.RLock()
 	handler := commandHandler
 	commandHandlerMu.RUnlock()
 
 	if handler != nil {
-		handler(command, msg.Data)
+		handler(command, data)
 	} else {
 		WarnCtx("No command handler registered", "command", command)
 	}
@@ -0,0 +1,162 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"printmaster/agent/watchdog"
+	"printmaster/common/config"
+
+	"github.com/kardianos/service"
+)
+
+var (
+	// agentWatchdog tracks in-flight scans and uploads; nil when disabled.
+	agentWatchdog        *watchdog.Monitor
+	watchdogStallTimeout atomic.Int64 // time.Duration
+)
+
+// watchdogBegin marks the start of a unit of work on a long-running loop and
+// returns the function that marks it done. A no-op when the watchdog is
+// disabled.
+func watchdogBegin(name string) func() {
+	return agentWatchdog.Begin(name, time.Duration(watchdogStallTimeout.Load()))
+}
+
+// isWatchdogChild reports whether this process is a worker started by the
+// watchdog supervisor.
+func isWatchdogChild() bool {
+	return os.Getenv(watchdog.EnvChild) != ""
+}
+
+// runningAsService reports whether the agent runs under the service manager,
+// either directly or as a supervised worker process.
+func runningAsService() bool {
+	return isWatchdogChild() || !service.Interactive()
+}
+
+// watchdogCrashDir returns where crash bundles are written.
+func watchdogCrashDir(cfg WatchdogConfig, logDir string) string {
+	if cfg.CrashDir != "" {
+		return cfg.CrashDir
+	}
+	return filepath.Join(logDir, "crash")
+}
+
+func watchdogInfo() map[string]string {
+	return map[string]string{
+		"version":    Version,
+		"build_time": BuildTime,
+		"git_commit": GitCommit,
+		"build_type": BuildType,
+	}
+}
+
+// startAgentWatchdog starts the supervisory goroutine. When a scan or upload
+// overruns the stall timeout it writes a crash bundle and, under the service
+// manager, exits so the agent is restarted.
+func startAgentWatchdog(ctx context.Context, cfg WatchdogConfig, logDir string) {
+	if !cfg.Enabled {
+		appLogger.Info("Watchdog disabled")
+		return
+	}
+	timeout := time.Duration(cfg.StallTimeoutMinutes) * time.Minute
+	if timeout <= 0 {
+		timeout = 30 * time.Minute
+	}
+	watchdogStallTimeout.Store(int64(timeout))
+	agentWatchdog = watchdog.NewMonitor()
+
+	heartbeat := os.Getenv(watchdog.EnvHeartbeatFile)
+	crashDir := watchdogCrashDir(cfg, logDir)
+	appLogger.Info("Watchdog enabled", "stall_timeout", timeout.String(), "child_process", isWatchdogChild(), "crash_dir", crashDir)
+
+	go watchdog.Run(ctx, watchdog.Config{
+		Monitor:       agentWatchdog,
+		HeartbeatFile: heartbeat,
+		CrashDir:      crashDir,
+		Info:          watchdogInfo(),
+		Logf: func(format string, args ...interface{}) {
+			appLogger.Warn(fmt.Sprintf(format, args...))
+		},
+		OnStall: func(stalls []watchdog.Stall, bundle string) {
+			for _, s := range stalls {
+				appLogger.Error("Watchdog: agent stalled", "loop", s.Name, "running", s.Running.Round(time.Second).String(), "timeout", s.Timeout.String())
+			}
+			if !runningAsService() {
+				appLogger.Error("Watchdog: restart the agent to recover", "crash_bundle", bundle)
+				return
+			}
+			appLogger.Error("Watchdog: exiting so the agent is restarted", "crash_bundle", bundle)
+			appLogger.Close()
+			os.Exit(watchdog.ExitStalled)
+		},
+	})
+}
+
+// runWatchdogWorker runs the agent as a supervised worker process until the
+// supervisor asks it to stop.
+func runWatchdogWorker(configFlag string) {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	runInteractive(ctx, configFlag)
+}
+
+// runWatchdogSupervisor runs the agent as a worker process under the service
+// wrapper and restarts it whenever it exits or stops sending heartbeats.
+func runWatchdogSupervisor(ctx context.Context, cfg WatchdogConfig, logf func(format string, args ...interface{})) error {
+	logDir := filepath.Dir(getServiceLogPath())
+	exe, err := os.Executable()
+	if err != nil {
+		return fmt.Errorf("locate agent executable: %w", err)
+	}
+	// The worker must miss several heartbeats before it counts as hung; the
+	// in-process watchdog handles slow scans itself.
+	sup := &watchdog.Supervisor{
+		Command: func() *exec.Cmd {
+			return exec.Command(exe)
+		},
+		HeartbeatFile: filepath.Join(logDir, "watchdog.heartbeat"),
+		StallTimeout:  3 * time.Minute,
+		CrashDir:      watchdogCrashDir(cfg, logDir),
+		Info:          watchdogInfo(),
+		Logf:          logf,
+	}
+	return sup.Run(ctx)
+}
+
+// loadServiceWatchdogConfig reads the watchdog settings the same way the
+// service resolves its configuration, falling back to defaults.
+func loadServiceWatchdogConfig() WatchdogConfig {
+	paths := serviceConfigPaths()
+	if resolved := config.ResolveConfigPath("AGENT", ""); resolved != "" {
+		paths = append([]string{resolved}, paths...)
+	}
+	for _, p := range paths {
+		if cfg, err := LoadAgentConfig(p); err == nil {
+			return cfg.Watchdog
+		}
+	}
+	cfg := DefaultAgentConfig()
+	ApplyEnvironmentOverrides(cfg)
+	return cfg.Watchdog
+}
+
+// serviceConfigPaths returns the config file locations checked in service mode.
+func serviceConfigPaths() []string {
+	programData := os.Getenv("ProgramData")
+	if programData == "" {
+		programData = "C:\\ProgramData"
+	}
+	return []string{
+		filepath.Join(programData, "PrintMaster", "agent", "config.toml"),
+		filepath.Join(programData, "PrintMaster", "config.toml"), // Legacy location
+	}
+}
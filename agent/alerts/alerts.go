@@ -0,0 +1,406 @@
+// Package alerts evaluates device metrics against local alert rules, so an
+// agent without a server still warns about low toner, paper jams and devices
+// that stop answering. The Engine only decides when alerts are raised and
+// resolved; storing, broadcasting and forwarding them is up to the caller.
+package alerts
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Rule types.
+const (
+	TypeTonerLow      = "toner_low"
+	TypePaperJam      = "paper_jam"
+	TypeDeviceOffline = "device_offline"
+)
+
+// Severities.
+const (
+	SeverityInfo     = "info"
+	SeverityWarning  = "warning"
+	SeverityCritical = "critical"
+)
+
+// Rule enables one alert type. Threshold is the toner percentage at or below
+// which toner_low fires, the number of new jams between two snapshots for
+// paper_jam, and the minutes a device must be unreachable for device_offline.
+type Rule struct {
+	Type      string `json:"type"`
+	Enabled   bool   `json:"enabled"`
+	Threshold int    `json:"threshold"`
+	Severity  string `json:"severity"`
+}
+
+// DefaultRules returns the rules used until the user changes them.
+func DefaultRules() []Rule {
+	return []Rule{
+		{Type: TypeTonerLow, Enabled: true, Threshold: 10, Severity: SeverityWarning},
+		{Type: TypePaperJam, Enabled: true, Threshold: 1, Severity: SeverityWarning},
+		{Type: TypeDeviceOffline, Enabled: true, Threshold: 30, Severity: SeverityCritical},
+	}
+}
+
+// ValidateRules checks types, thresholds and severities. Each type may appear
+// at most once.
+func ValidateRules(rules []Rule) error {
+	seen := make(map[string]bool, len(rules))
+	for _, r := range rules {
+		if seen[r.Type] {
+			return fmt.Errorf("duplicate rule %q", r.Type)
+		}
+		seen[r.Type] = true
+		switch r.Type {
+		case TypeTonerLow:
+			if r.Threshold < 1 || r.Threshold > 99 {
+				return fmt.Errorf("%s threshold must be between 1 and 99 percent", r.Type)
+			}
+		case TypePaperJam:
+			if r.Threshold < 1 {
+				return fmt.Errorf("%s threshold must be at least 1 jam", r.Type)
+			}
+		case TypeDeviceOffline:
+			if r.Threshold < 1 || r.Threshold > 7*24*60 {
+				return fmt.Errorf("%s threshold must be between 1 minute and 7 days", r.Type)
+			}
+		default:
+			return fmt.Errorf("unknown rule type %q", r.Type)
+		}
+		switch r.Severity {
+		case SeverityInfo, SeverityWarning, SeverityCritical:
+		default:
+			return fmt.Errorf("unknown severity %q for %s", r.Severity, r.Type)
+		}
+	}
+	return nil
+}
+
+// Device identifies the device an alert is about.
+type Device struct {
+	Serial string
+	IP     string
+	Name   string // Manufacturer and model, used in titles
+}
+
+func (d Device) label() string {
+	name := strings.TrimSpace(d.Name)
+	if name == "" {
+		name = "Printer"
+	}
+	if d.IP != "" {
+		return name + " at " + d.IP
+	}
+	return name
+}
+
+// Sample is one successful metrics collection for a device.
+type Sample struct {
+	Device
+	Timestamp   time.Time
+	TonerLevels map[string]interface{} // Supply name -> percent remaining
+	JamEvents   int                    // Lifetime jam counter; 0 when unknown
+}
+
+// Alert is a raised alert. Key identifies the condition (device, type and
+// supply) so it is raised only once until it resolves.
+type Alert struct {
+	Key       string    `json:"key"`
+	Type      string    `json:"type"`
+	Severity  string    `json:"severity"`
+	Serial    string    `json:"serial"`
+	IP        string    `json:"ip,omitempty"`
+	Supply    string    `json:"supply,omitempty"`
+	Title     string    `json:"title"`
+	Message   string    `json:"message"`
+	Value     int       `json:"value"`
+	Threshold int       `json:"threshold"`
+	RaisedAt  time.Time `json:"raised_at"`
+}
+
+// Key builds the condition key for an alert type on a device.
+func Key(serial, alertType, supply string) string {
+	key := serial + "|" + alertType
+	if supply != "" {
+		key += "|" + supply
+	}
+	return key
+}
+
+// Result lists what changed during one evaluation.
+type Result struct {
+	Raised   []Alert
+	Resolved []Alert
+}
+
+// Empty reports whether nothing changed.
+func (r Result) Empty() bool {
+	return len(r.Raised) == 0 && len(r.Resolved) == 0
+}
+
+// Engine tracks the open alerts and the per-device state rules need (last
+// jam counter, last successful contact).
+type Engine struct {
+	mu       sync.Mutex
+	rules    map[string]Rule
+	active   map[string]Alert
+	jams     map[string]int
+	lastSeen map[string]time.Time
+}
+
+// NewEngine returns an engine evaluating rules.
+func NewEngine(rules []Rule) *Engine {
+	e := &Engine{
+		active:   make(map[string]Alert),
+		jams:     make(map[string]int),
+		lastSeen: make(map[string]time.Time),
+	}
+	e.SetRules(rules)
+	return e
+}
+
+// SetRules replaces the rules. Alerts of rules that are now disabled resolve
+// the next time their device is evaluated.
+func (e *Engine) SetRules(rules []Rule) {
+	byType := make(map[string]Rule, len(rules))
+	for _, r := range rules {
+		byType[r.Type] = r
+	}
+	e.mu.Lock()
+	e.rules = byType
+	e.mu.Unlock()
+}
+
+// Rules returns the current rules ordered by type.
+func (e *Engine) Rules() []Rule {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	out := make([]Rule, 0, len(e.rules))
+	for _, r := range e.rules {
+		out = append(out, r)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Type < out[j].Type })
+	return out
+}
+
+// Restore seeds the alerts that were open when the agent last stopped, so
+// they are not raised a second time.
+func (e *Engine) Restore(open []Alert) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	for _, a := range open {
+		if a.Key != "" {
+			e.active[a.Key] = a
+		}
+	}
+}
+
+// Active returns the open alerts, newest first.
+func (e *Engine) Active() []Alert {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	out := make([]Alert, 0, len(e.active))
+	for _, a := range e.active {
+		out = append(out, a)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if !out[i].RaisedAt.Equal(out[j].RaisedAt) {
+			return out[i].RaisedAt.After(out[j].RaisedAt)
+		}
+		return out[i].Key < out[j].Key
+	})
+	return out
+}
+
+func (e *Engine) enabled(alertType string) (Rule, bool) {
+	r, ok := e.rules[alertType]
+	return r, ok && r.Enabled
+}
+
+func (e *Engine) raise(res *Result, a Alert) {
+	if _, open := e.active[a.Key]; open {
+		return
+	}
+	e.active[a.Key] = a
+	res.Raised = append(res.Raised, a)
+}
+
+func (e *Engine) resolve(res *Result, key string) {
+	if a, open := e.active[key]; open {
+		delete(e.active, key)
+		res.Resolved = append(res.Resolved, a)
+	}
+}
+
+// Observe evaluates a successful collection: the device is reachable again,
+// toner levels are compared with the threshold and the jam counter with the
+// previous sample.
+func (e *Engine) Observe(s Sample) Result {
+	var res Result
+	if s.Serial == "" {
+		return res
+	}
+	if s.Timestamp.IsZero() {
+		s.Timestamp = time.Now()
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.lastSeen[s.Serial] = s.Timestamp
+	e.resolve(&res, Key(s.Serial, TypeDeviceOffline, ""))
+
+	// Toner
+	toner, tonerOn := e.enabled(TypeTonerLow)
+	for supply, raw := range s.TonerLevels {
+		key := Key(s.Serial, TypeTonerLow, supply)
+		level, known := percent(raw)
+		if !tonerOn || (known && level > toner.Threshold) {
+			e.resolve(&res, key)
+			continue
+		}
+		if !known {
+			continue
+		}
+		e.raise(&res, Alert{
+			Key: key, Type: TypeTonerLow, Severity: toner.Severity,
+			Serial: s.Serial, IP: s.IP, Supply: supply,
+			Title:   "Toner low on " + s.label(),
+			Message: fmt.Sprintf("%s toner at %d%% (threshold %d%%)", supplyName(supply), level, toner.Threshold),
+			Value:   level, Threshold: toner.Threshold, RaisedAt: s.Timestamp,
+		})
+	}
+	if !tonerOn {
+		e.resolveType(&res, s.Serial, TypeTonerLow)
+	}
+
+	// Paper jams: compare with the previous counter; a lower value means the
+	// counter was reset and becomes the new baseline.
+	jamKey := Key(s.Serial, TypePaperJam, "")
+	jam, jamOn := e.enabled(TypePaperJam)
+	previous, baseline := e.jams[s.Serial]
+	if s.JamEvents > 0 {
+		e.jams[s.Serial] = s.JamEvents
+	}
+	switch {
+	case !jamOn:
+		e.resolve(&res, jamKey)
+	case baseline && s.JamEvents-previous >= jam.Threshold:
+		added := s.JamEvents - previous
+		noun := "jams"
+		if added == 1 {
+			noun = "jam"
+		}
+		e.raise(&res, Alert{
+			Key: jamKey, Type: TypePaperJam, Severity: jam.Severity,
+			Serial: s.Serial, IP: s.IP,
+			Title:   "Paper jam on " + s.label(),
+			Message: fmt.Sprintf("%d new %s (%d total)", added, noun, s.JamEvents),
+			Value:   added, Threshold: jam.Threshold, RaisedAt: s.Timestamp,
+		})
+	case s.JamEvents <= previous || !baseline:
+		e.resolve(&res, jamKey)
+	}
+	return res
+}
+
+// resolveType resolves every open alert of alertType on serial.
+func (e *Engine) resolveType(res *Result, serial, alertType string) {
+	prefix := Key(serial, alertType, "")
+	for key := range e.active {
+		if key == prefix || strings.HasPrefix(key, prefix+"|") {
+			e.resolve(res, key)
+		}
+	}
+}
+
+// Unreachable evaluates a failed collection. lastSeen is when the device was
+// last known to answer (its last_seen from discovery); the later of it and
+// the last observed sample counts.
+func (e *Engine) Unreachable(d Device, lastSeen, now time.Time) Result {
+	var res Result
+	if d.Serial == "" {
+		return res
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	key := Key(d.Serial, TypeDeviceOffline, "")
+	rule, on := e.enabled(TypeDeviceOffline)
+	if !on {
+		e.resolve(&res, key)
+		return res
+	}
+	if seen := e.lastSeen[d.Serial]; seen.After(lastSeen) {
+		lastSeen = seen
+	}
+	if lastSeen.IsZero() {
+		// Never seen answering since the agent started: start the clock now
+		e.lastSeen[d.Serial] = now
+		return res
+	}
+	down := now.Sub(lastSeen)
+	if down < time.Duration(rule.Threshold)*time.Minute {
+		return res
+	}
+	minutes := int(down / time.Minute)
+	e.raise(&res, Alert{
+		Key: key, Type: TypeDeviceOffline, Severity: rule.Severity,
+		Serial: d.Serial, IP: d.IP,
+		Title:   d.label() + " is offline",
+		Message: fmt.Sprintf("No response for %s (threshold %d minutes)", down.Round(time.Minute), rule.Threshold),
+		Value:   minutes, Threshold: rule.Threshold, RaisedAt: now,
+	})
+	return res
+}
+
+// Forget drops all state for a deleted device and returns its open alerts as
+// resolved.
+func (e *Engine) Forget(serial string) Result {
+	var res Result
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	for key, a := range e.active {
+		if a.Serial == serial {
+			e.resolve(&res, key)
+		}
+	}
+	delete(e.jams, serial)
+	delete(e.lastSeen, serial)
+	return res
+}
+
+// percent converts a toner level to a percentage. Negative values are SNMP
+// "unknown" or "some remaining" markers and are not levels.
+func percent(v interface{}) (int, bool) {
+	var f float64
+	switch n := v.(type) {
+	case int:
+		f = float64(n)
+	case int32:
+		f = float64(n)
+	case int64:
+		f = float64(n)
+	case float32:
+		f = float64(n)
+	case float64:
+		f = n
+	default:
+		return 0, false
+	}
+	if f < 0 || f > 100 {
+		return 0, false
+	}
+	return int(f + 0.5), true
+}
+
+// supplyName capitalizes a supply key for messages ("black" -> "Black").
+func supplyName(supply string) string {
+	supply = strings.ReplaceAll(strings.TrimSpace(supply), "_", " ")
+	if supply == "" {
+		return "Toner"
+	}
+	return strings.ToUpper(supply[:1]) + supply[1:]
+}
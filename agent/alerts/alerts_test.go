@@ -0,0 +1,92 @@
+package alerts
+
+import (
+	"testing"
+	"time"
+)
+
+func TestEngineTonerAndJams(t *testing.T) {
+	e := NewEngine(DefaultRules())
+	start := time.Date(2026, 3, 1, 9, 0, 0, 0, time.UTC)
+	dev := Device{Serial: "SN1", IP: "10.0.0.5", Name: "HP LaserJet"}
+
+	res := e.Observe(Sample{Device: dev, Timestamp: start, JamEvents: 4,
+		TonerLevels: map[string]interface{}{"black": 8, "cyan": 55.0, "magenta": -2}})
+	if len(res.Raised) != 1 || res.Raised[0].Key != Key("SN1", TypeTonerLow, "black") || res.Raised[0].Value != 8 {
+		t.Fatalf("expected one black toner alert, got %+v", res)
+	}
+
+	// Still low: not raised again. Two new jams raise a jam alert.
+	res = e.Observe(Sample{Device: dev, Timestamp: start.Add(time.Hour), JamEvents: 6,
+		TonerLevels: map[string]interface{}{"black": 7}})
+	if len(res.Raised) != 1 || res.Raised[0].Type != TypePaperJam || res.Raised[0].Value != 2 {
+		t.Fatalf("expected only a jam alert, got %+v", res)
+	}
+
+	// Toner replaced and no new jams: both resolve
+	res = e.Observe(Sample{Device: dev, Timestamp: start.Add(2 * time.Hour), JamEvents: 6,
+		TonerLevels: map[string]interface{}{"black": 100}})
+	if len(res.Raised) != 0 || len(res.Resolved) != 2 {
+		t.Fatalf("expected both alerts to resolve, got %+v", res)
+	}
+	if len(e.Active()) != 0 {
+		t.Fatalf("alerts still open: %+v", e.Active())
+	}
+}
+
+func TestEngineOffline(t *testing.T) {
+	e := NewEngine(DefaultRules())
+	start := time.Date(2026, 3, 1, 9, 0, 0, 0, time.UTC)
+	dev := Device{Serial: "SN1", IP: "10.0.0.5"}
+	e.Observe(Sample{Device: dev, Timestamp: start})
+
+	if res := e.Unreachable(dev, time.Time{}, start.Add(10*time.Minute)); !res.Empty() {
+		t.Fatalf("raised before the threshold: %+v", res)
+	}
+	res := e.Unreachable(dev, time.Time{}, start.Add(31*time.Minute))
+	if len(res.Raised) != 1 || res.Raised[0].Type != TypeDeviceOffline || res.Raised[0].Severity != SeverityCritical {
+		t.Fatalf("expected an offline alert, got %+v", res)
+	}
+	if res := e.Unreachable(dev, time.Time{}, start.Add(40*time.Minute)); !res.Empty() {
+		t.Fatalf("offline alert raised twice: %+v", res)
+	}
+	res = e.Observe(Sample{Device: dev, Timestamp: start.Add(45 * time.Minute)})
+	if len(res.Resolved) != 1 || res.Resolved[0].Type != TypeDeviceOffline {
+		t.Fatalf("expected the offline alert to resolve, got %+v", res)
+	}
+}
+
+func TestEngineRestoreAndDisabledRules(t *testing.T) {
+	e := NewEngine(DefaultRules())
+	open := Alert{Key: Key("SN1", TypeTonerLow, "black"), Type: TypeTonerLow, Serial: "SN1", Supply: "black"}
+	e.Restore([]Alert{open})
+
+	dev := Device{Serial: "SN1"}
+	if res := e.Observe(Sample{Device: dev, TonerLevels: map[string]interface{}{"black": 5}}); len(res.Raised) != 0 {
+		t.Fatalf("restored alert raised again: %+v", res)
+	}
+
+	rules := DefaultRules()
+	rules[0].Enabled = false
+	e.SetRules(rules)
+	if res := e.Observe(Sample{Device: dev, TonerLevels: map[string]interface{}{"black": 5}}); len(res.Resolved) != 1 {
+		t.Fatalf("disabled rule did not resolve its alert: %+v", res)
+	}
+}
+
+func TestValidateRules(t *testing.T) {
+	if err := ValidateRules(DefaultRules()); err != nil {
+		t.Fatalf("default rules invalid: %v", err)
+	}
+	bad := [][]Rule{
+		{{Type: TypeTonerLow, Threshold: 0, Severity: SeverityWarning}},
+		{{Type: "fuser", Threshold: 1, Severity: SeverityWarning}},
+		{{Type: TypePaperJam, Threshold: 1, Severity: "loud"}},
+		{{Type: TypePaperJam, Threshold: 1, Severity: SeverityInfo}, {Type: TypePaperJam, Threshold: 2, Severity: SeverityInfo}},
+	}
+	for _, rules := range bad {
+		if err := ValidateRules(rules); err == nil {
+			t.Errorf("expected an error for %+v", rules)
+		}
+	}
+}
@@ -0,0 +1,253 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"printmaster/agent/storage"
+	"printmaster/common/qrcode"
+)
+
+// Asset tags are QR codes printed and stuck on devices. Each code holds a
+// link to the agent's mobile asset page (/asset?code=SERIAL), so scanning it
+// with a phone opens the device and lets a technician confirm or correct its
+// asset number and location during a physical audit.
+
+// assetTagURL returns the link encoded in a device's QR code, based on the
+// address the request reached the agent on.
+func assetTagURL(r *http.Request, serial string) string {
+	scheme := "http"
+	if requestIsHTTPS(r) {
+		scheme = "https"
+	}
+	host := r.Host
+	if host == "" {
+		host = "localhost"
+	}
+	return scheme + "://" + host + "/asset?code=" + url.QueryEscape(serial)
+}
+
+// assetCodeValue extracts the device reference from a scanned code. Codes
+// may be a full asset URL, or a bare serial or asset number typed in by hand.
+func assetCodeValue(code string) string {
+	code = strings.TrimSpace(code)
+	if u, err := url.Parse(code); err == nil && u.Scheme != "" && u.Host != "" {
+		q := u.Query()
+		for _, key := range []string{"code", "serial"} {
+			if v := strings.TrimSpace(q.Get(key)); v != "" {
+				return v
+			}
+		}
+		return strings.TrimSpace(u.Path[strings.LastIndex(u.Path, "/")+1:])
+	}
+	return code
+}
+
+// resolveAssetCode finds the device a scanned code refers to, by serial
+// first and then by asset number.
+func resolveAssetCode(ctx context.Context, store storage.DeviceStore, code string) (*storage.Device, error) {
+	value := assetCodeValue(code)
+	if value == "" {
+		return nil, storage.ErrNotFound
+	}
+	device, err := store.Get(ctx, value)
+	if err == nil {
+		return device, nil
+	}
+	if !errors.Is(err, storage.ErrNotFound) {
+		return nil, err
+	}
+	devices, err := store.List(ctx, storage.DeviceFilter{})
+	if err != nil {
+		return nil, err
+	}
+	for _, d := range devices {
+		if d.AssetNumber != "" && strings.EqualFold(d.AssetNumber, value) {
+			return d, nil
+		}
+	}
+	return nil, storage.ErrNotFound
+}
+
+func deviceFieldLocked(device *storage.Device, field string) bool {
+	for _, lf := range device.LockedFields {
+		if strings.EqualFold(lf.Field, field) {
+			return true
+		}
+	}
+	return false
+}
+
+func assetTagPayload(device *storage.Device) map[string]interface{} {
+	locked := []string{}
+	for _, field := range []string{"asset_number", "location"} {
+		if deviceFieldLocked(device, field) {
+			locked = append(locked, field)
+		}
+	}
+	return map[string]interface{}{
+		"serial":        device.Serial,
+		"manufacturer":  device.Manufacturer,
+		"model":         device.Model,
+		"ip":            device.IP,
+		"hostname":      device.Hostname,
+		"asset_number":  device.AssetNumber,
+		"location":      device.Location,
+		"last_seen":     device.LastSeen,
+		"locked_fields": locked,
+	}
+}
+
+// registerAssetTagHandlers exposes QR asset tags and the mobile asset page.
+func registerAssetTagHandlers() {
+	// GET /api/devices/qr?serial=SERIAL&scale=6 - SVG QR code linking to the device's asset page
+	http.HandleFunc("/api/devices/qr", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "GET only", http.StatusMethodNotAllowed)
+			return
+		}
+		serial := strings.TrimSpace(r.URL.Query().Get("serial"))
+		if serial == "" {
+			http.Error(w, "serial parameter required", http.StatusBadRequest)
+			return
+		}
+		ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+		defer cancel()
+		if _, err := deviceStore.Get(ctx, serial); err != nil {
+			http.Error(w, "device not found", http.StatusNotFound)
+			return
+		}
+
+		scale := 6
+		if s := r.URL.Query().Get("scale"); s != "" {
+			if n, err := strconv.Atoi(s); err == nil && n > 0 && n <= 32 {
+				scale = n
+			}
+		}
+		code, err := qrcode.Encode(assetTagURL(r, serial))
+		if err != nil {
+			http.Error(w, "failed to encode QR code: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		w.Header().Set("Content-Type", "image/svg+xml")
+		w.Header().Set("Cache-Control", "private, max-age=3600")
+		if r.URL.Query().Get("download") == "1" {
+			w.Header().Set("Content-Disposition", `attachment; filename="asset-tag-`+url.PathEscape(serial)+`.svg"`)
+		}
+		w.Write(code.SVG(scale))
+	})
+
+	// GET  /api/devices/asset-tag?code=... - Resolve a scanned code to a device
+	// POST /api/devices/asset-tag          - Update asset number/location from the field
+	http.HandleFunc("/api/devices/asset-tag", func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+		defer cancel()
+
+		switch r.Method {
+		case http.MethodGet:
+			code := r.URL.Query().Get("code")
+			if strings.TrimSpace(code) == "" {
+				http.Error(w, "code parameter required", http.StatusBadRequest)
+				return
+			}
+			device, err := resolveAssetCode(ctx, deviceStore, code)
+			if err != nil {
+				if errors.Is(err, storage.ErrNotFound) {
+					http.Error(w, "no device matches this code", http.StatusNotFound)
+					return
+				}
+				http.Error(w, "lookup failed: "+err.Error(), http.StatusInternalServerError)
+				return
+			}
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(assetTagPayload(device))
+
+		case http.MethodPost:
+			var req struct {
+				Code        string  `json:"code"`
+				AssetNumber *string `json:"asset_number,omitempty"`
+				Location    *string `json:"location,omitempty"`
+			}
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+				http.Error(w, "bad json", http.StatusBadRequest)
+				return
+			}
+			if strings.TrimSpace(req.Code) == "" {
+				http.Error(w, "code required", http.StatusBadRequest)
+				return
+			}
+			device, err := resolveAssetCode(ctx, deviceStore, req.Code)
+			if err != nil {
+				if errors.Is(err, storage.ErrNotFound) {
+					http.Error(w, "no device matches this code", http.StatusNotFound)
+					return
+				}
+				http.Error(w, "lookup failed: "+err.Error(), http.StatusInternalServerError)
+				return
+			}
+
+			var skipped []string
+			changed := false
+			if req.AssetNumber != nil {
+				if deviceFieldLocked(device, "asset_number") {
+					skipped = append(skipped, "asset_number")
+				} else if v := strings.TrimSpace(*req.AssetNumber); v != device.AssetNumber {
+					device.AssetNumber, changed = v, true
+				}
+			}
+			if req.Location != nil {
+				if deviceFieldLocked(device, "location") {
+					skipped = append(skipped, "location")
+				} else if v := strings.TrimSpace(*req.Location); v != device.Location {
+					device.Location, changed = v, true
+				}
+			}
+
+			if changed {
+				if err := deviceStore.Update(ctx, device); err != nil {
+					appLogger.Error("Asset tag update failed", "serial", device.Serial, "error", err)
+					http.Error(w, "update failed: "+err.Error(), http.StatusInternalServerError)
+					return
+				}
+				username := ""
+				if p, ok := r.Context().Value(agentPrincipalContextKey).(*AgentPrincipal); ok && p != nil {
+					username = p.Username
+				}
+				appLogger.Info("Asset tag updated", "serial", device.Serial, "asset_number", device.AssetNumber,
+					"location", device.Location, "by", username)
+			}
+
+			resp := assetTagPayload(device)
+			resp["updated"] = changed
+			if len(skipped) > 0 {
+				resp["skipped_locked"] = skipped
+			}
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(resp)
+
+		default:
+			http.Error(w, "GET or POST only", http.StatusMethodNotAllowed)
+		}
+	})
+
+	// GET /asset?code=... - Mobile page opened by scanning an asset tag
+	http.HandleFunc("/asset", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "only GET allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		data, err := webFS.ReadFile("web/asset.html")
+		if err != nil {
+			http.Error(w, "asset page not available", http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Write(data)
+	})
+}
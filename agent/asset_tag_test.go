@@ -0,0 +1,63 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http/httptest"
+	"testing"
+
+	"printmaster/agent/storage"
+)
+
+func TestAssetCodeValue(t *testing.T) {
+	t.Parallel()
+	cases := map[string]string{
+		"https://agent.local:8443/asset?code=JPBCD12345": "JPBCD12345",
+		"http://10.0.0.5:8080/asset?serial=SN%2F1":       "SN/1",
+		"https://server.example/devices/CNB123":          "CNB123",
+		"  AST-0042 ":                                    "AST-0042",
+	}
+	for in, want := range cases {
+		if got := assetCodeValue(in); got != want {
+			t.Errorf("assetCodeValue(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestAssetTagURL(t *testing.T) {
+	t.Parallel()
+	req := httptest.NewRequest("GET", "/api/devices/qr?serial=SN/1", nil)
+	req.Host = "agent.local:8080"
+	req.Header.Set("X-Forwarded-Proto", "https")
+	if got := assetTagURL(req, "SN/1"); got != "https://agent.local:8080/asset?code=SN%2F1" {
+		t.Fatalf("assetTagURL = %q", got)
+	}
+}
+
+func TestResolveAssetCode(t *testing.T) {
+	store, err := storage.NewSQLiteStore(":memory:")
+	if err != nil {
+		t.Fatalf("NewSQLiteStore: %v", err)
+	}
+	defer store.Close()
+	ctx := context.Background()
+
+	device := &storage.Device{}
+	device.Serial = "JPBCD12345"
+	device.IP = "10.0.0.9"
+	device.AssetNumber = "AST-0042"
+	device.IsSaved = true
+	if err := store.Create(ctx, device); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+
+	for _, code := range []string{"JPBCD12345", "https://agent.local/asset?code=JPBCD12345", "ast-0042"} {
+		got, err := resolveAssetCode(ctx, store, code)
+		if err != nil || got.Serial != "JPBCD12345" {
+			t.Errorf("resolveAssetCode(%q) = %v, %v", code, got, err)
+		}
+	}
+	if _, err := resolveAssetCode(ctx, store, "UNKNOWN"); !errors.Is(err, storage.ErrNotFound) {
+		t.Errorf("expected ErrNotFound, got %v", err)
+	}
+}
@@ -0,0 +1,218 @@
+package main
+
+import (
+	"crypto/pbkdf2"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// Agent UI roles. They match the server's user roles so principals issued by
+// server auth carry over unchanged.
+const (
+	agentRoleViewer   = "viewer"
+	agentRoleOperator = "operator"
+	agentRoleAdmin    = "admin"
+)
+
+// normalizeAgentRole maps a role claim to a known role. Unknown or empty
+// values get the least privileged role.
+func normalizeAgentRole(role string) string {
+	switch strings.ToLower(strings.TrimSpace(role)) {
+	case agentRoleAdmin:
+		return agentRoleAdmin
+	case agentRoleOperator:
+		return agentRoleOperator
+	default:
+		return agentRoleViewer
+	}
+}
+
+func agentRoleRank(role string) int {
+	switch normalizeAgentRole(role) {
+	case agentRoleAdmin:
+		return 3
+	case agentRoleOperator:
+		return 2
+	default:
+		return 1
+	}
+}
+
+// HasRole reports whether the principal's role is at least minRole.
+func (p *AgentPrincipal) HasRole(minRole string) bool {
+	if p == nil {
+		return false
+	}
+	return agentRoleRank(p.Role) >= agentRoleRank(minRole)
+}
+
+// Route policy
+//
+// Reads (GET/HEAD) need viewer and everything else needs admin, except for
+// the routes below. Operators may trigger scans, collections and device
+// probes; a few reads are admin-only because they expose secrets.
+var agentOperatorRoutes = map[string]struct{}{
+	"/discover":                 {},
+	"/discover_now":             {},
+	"/devices/refresh":          {},
+	"/devices/preview":          {},
+	"/devices/save":             {},
+	"/devices/save/all":         {},
+	"/devices/clear_discovered": {},
+	"/devices/metrics/collect":  {},
+	"/api/meter-reads/capture":  {},
+	"/api/deep-scan":            {}, // Server-scheduled deep scans
+	"/api/devices/asset-tag":    {}, // Field updates of asset number/location
+	"/api/usb-printers/scan":    {},
+	"/api/report":               {},
+	"/api/report/stream":        {},
+	"/api/autoupdate/check":     {},
+	"/settings/server/retry":    {},
+}
+
+var agentOperatorPrefixes = []string{
+	"/proxy/",                  // Device web UIs can change device configuration
+	"/api/usb-printers/probe/", // Probing talks to the printer
+}
+
+var agentAdminReadRoutes = map[string]struct{}{
+	"/device/webui-credentials": {},
+	"/logfile":                  {},
+	"/logs/archive":             {},
+	"/api/snmp/history":         {}, // OID browser is admin-only
+	"/api/plugins":              {}, // Plugin paths and errors
+	"/api/guest-links":          {},
+	"/api/guest-links/audit":    {},
+}
+
+// requiredAgentRole returns the minimum role needed to serve r.
+func requiredAgentRole(r *http.Request) string {
+	path := r.URL.Path
+	if _, ok := agentAdminReadRoutes[path]; ok {
+		return agentRoleAdmin
+	}
+	if _, ok := agentOperatorRoutes[path]; ok {
+		return agentRoleOperator
+	}
+	for _, prefix := range agentOperatorPrefixes {
+		if strings.HasPrefix(path, prefix) {
+			return agentRoleOperator
+		}
+	}
+	switch r.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return agentRoleViewer
+	default:
+		return agentRoleAdmin
+	}
+}
+
+// Local accounts
+//
+// Passwords are stored as "pbkdf2-sha256$<iterations>$<salt>$<key>" with
+// base64 (raw, standard alphabet) salt and key. Generate them with
+// `printmaster-agent hash-password`.
+
+const (
+	agentPasswordScheme     = "pbkdf2-sha256"
+	agentPasswordIterations = 600000
+	agentPasswordSaltLen    = 16
+	agentPasswordKeyLen     = 32
+)
+
+var errInvalidPasswordHash = errors.New("invalid password hash")
+
+// hashAgentPassword derives a storable hash for a local account password.
+func hashAgentPassword(password string) (string, error) {
+	salt := make([]byte, agentPasswordSaltLen)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+	key, err := pbkdf2.Key(sha256.New, password, salt, agentPasswordIterations, agentPasswordKeyLen)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%s$%d$%s$%s", agentPasswordScheme, agentPasswordIterations,
+		base64.RawStdEncoding.EncodeToString(salt), base64.RawStdEncoding.EncodeToString(key)), nil
+}
+
+// verifyAgentPassword checks password against a hash from hashAgentPassword.
+func verifyAgentPassword(encoded, password string) (bool, error) {
+	parts := strings.Split(encoded, "$")
+	if len(parts) != 4 || parts[0] != agentPasswordScheme {
+		return false, errInvalidPasswordHash
+	}
+	iterations, err := strconv.Atoi(parts[1])
+	if err != nil || iterations <= 0 {
+		return false, errInvalidPasswordHash
+	}
+	salt, err := base64.RawStdEncoding.DecodeString(parts[2])
+	if err != nil {
+		return false, errInvalidPasswordHash
+	}
+	want, err := base64.RawStdEncoding.DecodeString(parts[3])
+	if err != nil || len(want) == 0 {
+		return false, errInvalidPasswordHash
+	}
+	got, err := pbkdf2.Key(sha256.New, password, salt, iterations, len(want))
+	if err != nil {
+		return false, err
+	}
+	return subtle.ConstantTimeCompare(got, want) == 1, nil
+}
+
+// localLogin authenticates against the local accounts from [[web.auth.users]].
+func (a *agentAuthManager) localLogin(username, password string) (*AgentPrincipal, error) {
+	account, ok := a.localUsers[strings.ToLower(username)]
+	if !ok {
+		return nil, errInvalidCredentials
+	}
+	match, err := verifyAgentPassword(account.PasswordHash, password)
+	if err != nil {
+		if appLogger != nil {
+			appLogger.Warn("Local account has an invalid password hash", "username", account.Username)
+		}
+		return nil, errInvalidCredentials
+	}
+	if !match {
+		return nil, errInvalidCredentials
+	}
+	return &AgentPrincipal{
+		Username: account.Username,
+		Role:     normalizeAgentRole(account.Role),
+		Source:   "local",
+	}, nil
+}
+
+// serverProxyPrincipal builds the principal for a request proxied by the
+// server, which forwards the authenticated user and role as headers.
+func serverProxyPrincipal(r *http.Request) *AgentPrincipal {
+	username := strings.TrimSpace(r.Header.Get("X-PrintMaster-User"))
+	if username == "" {
+		username = "server"
+	}
+	return &AgentPrincipal{
+		Username: username,
+		Role:     normalizeAgentRole(r.Header.Get("X-PrintMaster-Role")),
+		Source:   "server-proxy",
+	}
+}
+
+// stripServerProxyHeaders removes the headers that mark a request as proxied
+// by the server. Only the in-process proxy path may set them, so they are
+// dropped from everything arriving over the network.
+func stripServerProxyHeaders(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.Header.Del("X-PrintMaster-Proxy")
+		r.Header.Del("X-PrintMaster-User")
+		r.Header.Del("X-PrintMaster-Role")
+		next.ServeHTTP(w, r)
+	})
+}
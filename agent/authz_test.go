@@ -0,0 +1,154 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRequiredAgentRole(t *testing.T) {
+	cases := []struct {
+		method, path, want string
+	}{
+		{http.MethodGet, "/devices/list", agentRoleViewer},
+		{http.MethodGet, "/settings", agentRoleViewer},
+		{http.MethodPost, "/settings", agentRoleAdmin},
+		{http.MethodPost, "/settings/join", agentRoleAdmin},
+		{http.MethodPost, "/devices/delete", agentRoleAdmin},
+		{http.MethodPost, "/discover_now", agentRoleOperator},
+		{http.MethodGet, "/discover", agentRoleOperator},
+		{http.MethodPost, "/devices/metrics/collect", agentRoleOperator},
+		{http.MethodPost, "/api/devices/asset-tag", agentRoleOperator},
+		{http.MethodGet, "/proxy/CNB123/index.html", agentRoleOperator},
+		{http.MethodGet, "/device/webui-credentials", agentRoleAdmin},
+		{http.MethodPost, "/api/snmp/query", agentRoleAdmin},
+		{http.MethodGet, "/api/snmp/history", agentRoleAdmin},
+		{http.MethodPost, "/api/deep-scan", agentRoleOperator},
+		{http.MethodGet, "/api/deep-scan", agentRoleOperator},
+		{http.MethodPost, "/api/devices/writeback", agentRoleAdmin},
+		{http.MethodGet, "/api/discovery/methods", agentRoleViewer},
+		{http.MethodPost, "/api/discovery/methods", agentRoleAdmin},
+		{http.MethodGet, "/api/v1/traps", agentRoleViewer},
+		{http.MethodGet, "/api/v1/traps/status", agentRoleViewer},
+	}
+	for _, tc := range cases {
+		req := httptest.NewRequest(tc.method, tc.path, nil)
+		if got := requiredAgentRole(req); got != tc.want {
+			t.Errorf("%s %s: got %s, want %s", tc.method, tc.path, got, tc.want)
+		}
+	}
+}
+
+func TestAgentAuthWrapEnforcesRoles(t *testing.T) {
+	cfg := DefaultAgentConfig()
+	cfg.Web.Auth.AllowLocalAdmin = false
+	auth := newAgentAuthManager(cfg, newAgentSessionManager())
+	handler := auth.Wrap(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+
+	serve := func(method, path, role string) int {
+		req := httptest.NewRequest(method, path, nil)
+		if role != "" {
+			token := auth.sessions.Create(&AgentPrincipal{Username: role, Role: role, Source: "test"}, "", time.Time{})
+			req.AddCookie(&http.Cookie{Name: agentSessionCookieName, Value: token})
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	if code := serve(http.MethodGet, "/devices/list", ""); code != http.StatusUnauthorized {
+		t.Fatalf("anonymous read: %d", code)
+	}
+	checks := []struct {
+		method, path, role string
+		want               int
+	}{
+		{http.MethodGet, "/devices/list", agentRoleViewer, http.StatusNoContent},
+		{http.MethodPost, "/discover_now", agentRoleViewer, http.StatusForbidden},
+		{http.MethodPost, "/discover_now", agentRoleOperator, http.StatusNoContent},
+		{http.MethodPost, "/settings", agentRoleOperator, http.StatusForbidden},
+		{http.MethodPost, "/settings", agentRoleAdmin, http.StatusNoContent},
+		{http.MethodPost, "/devices/metrics/collect", "bogus", http.StatusForbidden},
+	}
+	for _, c := range checks {
+		if code := serve(c.method, c.path, c.role); code != c.want {
+			t.Errorf("%s %s as %s: got %d, want %d", c.method, c.path, c.role, code, c.want)
+		}
+	}
+}
+
+func TestAgentAuthServerProxyRole(t *testing.T) {
+	auth := newAgentAuthManager(DefaultAgentConfig(), newAgentSessionManager())
+	handler := auth.Wrap(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+
+	proxied := func(role string) *http.Request {
+		req := httptest.NewRequest(http.MethodPost, "/settings", nil)
+		req.Header.Set("X-PrintMaster-Proxy", "server")
+		req.Header.Set("X-PrintMaster-User", "alice")
+		req.Header.Set("X-PrintMaster-Role", role)
+		return req
+	}
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, proxied(agentRoleOperator))
+	if rec.Code != http.StatusForbidden {
+		t.Fatalf("proxied operator changing settings: %d", rec.Code)
+	}
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, proxied(agentRoleAdmin))
+	if rec.Code != http.StatusNoContent {
+		t.Fatalf("proxied admin changing settings: %d", rec.Code)
+	}
+
+	// Headers arriving over the network are dropped, so a remote client
+	// cannot claim to be the server proxy
+	req := proxied(agentRoleAdmin)
+	req.RemoteAddr = "192.0.2.10:5000"
+	rec = httptest.NewRecorder()
+	stripServerProxyHeaders(handler).ServeHTTP(rec, req)
+	if rec.Code != http.StatusUnauthorized {
+		t.Fatalf("spoofed proxy headers: %d", rec.Code)
+	}
+}
+
+func TestAgentLocalAccountLogin(t *testing.T) {
+	hash, err := hashAgentPassword("s3cret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg := DefaultAgentConfig()
+	cfg.Web.Auth.Users = []WebAuthUser{{Username: "FrontDesk", PasswordHash: hash, Role: "operator"}}
+	auth := newAgentAuthManager(cfg, newAgentSessionManager())
+	if !auth.optionsPayload().LoginSupported {
+		t.Fatal("login should be supported when local accounts exist")
+	}
+
+	login := func(username, password string) *httptest.ResponseRecorder {
+		body := `{"username":"` + username + `","password":"` + password + `"}`
+		rec := httptest.NewRecorder()
+		auth.handleAuthLogin(rec, httptest.NewRequest(http.MethodPost, "/api/v1/auth/login", strings.NewReader(body)))
+		return rec
+	}
+
+	if rec := login("frontdesk", "wrong"); rec.Code != http.StatusUnauthorized {
+		t.Fatalf("wrong password: %d", rec.Code)
+	}
+	rec := login("frontdesk", "s3cret")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("login: %d %s", rec.Code, rec.Body.String())
+	}
+	cookies := rec.Result().Cookies()
+	if len(cookies) == 0 {
+		t.Fatal("no session cookie issued")
+	}
+	sess, ok := auth.sessions.Get(cookies[0].Value)
+	if !ok || sess.Principal.Role != agentRoleOperator || sess.Principal.Source != "local" {
+		t.Fatalf("unexpected session principal: %+v", sess)
+	}
+}
@@ -0,0 +1,128 @@
+package autoupdate
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"time"
+
+	"printmaster/common/logger"
+	"printmaster/common/updatepolicy"
+)
+
+// msiLogFile is written by msiexec in the update state directory.
+const msiLogFile = "msi_update.log"
+
+// diagnosticEnvVars are the environment variables worth attaching to a failure
+// report. Values are never secrets; anything else in the environment is left out.
+var diagnosticEnvVars = []string{
+	"PRINTMASTER_WATCHDOG_CHILD",
+	"INVOCATION_ID", // set by systemd
+	"container",     // set by most container runtimes
+	"PROCESSOR_ARCHITECTURE",
+	"PROCESSOR_ARCHITEW6432", // 32-bit process on 64-bit Windows
+}
+
+// collectDiagnostics gathers the autoupdate log lines since since, the tail of
+// the installer log and details about the install environment.
+func (m *Manager) collectDiagnostics(since time.Time) *updatepolicy.FailureDiagnostics {
+	d := &updatepolicy.FailureDiagnostics{
+		CollectedAt:   m.clock(),
+		OS:            m.platform,
+		Arch:          m.arch,
+		GoVersion:     runtime.Version(),
+		Channel:       m.channel,
+		InstallMethod: m.installMethod(),
+		IsService:     m.isService,
+		BinaryPath:    m.binaryPath,
+	}
+	if host, err := os.Hostname(); err == nil {
+		d.Hostname = host
+	}
+	if free, err := getAvailableDiskSpaceMB(m.stateDir); err == nil {
+		d.DiskFreeMB = free
+	}
+	for _, key := range diagnosticEnvVars {
+		if v, ok := os.LookupEnv(key); ok {
+			if d.Environment == nil {
+				d.Environment = make(map[string]string)
+			}
+			d.Environment[key] = v
+		}
+	}
+	if m.log != nil {
+		d.LogTail = formatLogTail(m.log.GetBuffer(), since, updatepolicy.MaxDiagnosticLogLines)
+	}
+	if m.useMSI {
+		d.InstallerLog = readFileTail(filepath.Join(m.stateDir, msiLogFile), updatepolicy.MaxDiagnosticInstallerLog)
+	}
+	return d
+}
+
+// installMethod returns how this agent applies updates.
+func (m *Manager) installMethod() string {
+	switch {
+	case m.usePackageManager && m.packageManager != "":
+		return m.packageManager
+	case m.useMSI:
+		return "msi"
+	default:
+		return "binary"
+	}
+}
+
+// formatLogTail renders the last max log entries written at or after since.
+func formatLogTail(entries []logger.LogEntry, since time.Time, max int) []string {
+	var lines []string
+	for _, e := range entries {
+		if !since.IsZero() && e.Timestamp.Before(since) {
+			continue
+		}
+		line := fmt.Sprintf("%s [%s] %s", e.Timestamp.UTC().Format(time.RFC3339), logger.LevelToString(e.Level), e.Message)
+		keys := make([]string, 0, len(e.Context))
+		for k := range e.Context {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			line += fmt.Sprintf(" %s=%v", k, e.Context[k])
+		}
+		if len(line) > updatepolicy.MaxDiagnosticLogLineLength {
+			line = line[:updatepolicy.MaxDiagnosticLogLineLength] + "..."
+		}
+		lines = append(lines, line)
+	}
+	if len(lines) > max {
+		lines = lines[len(lines)-max:]
+	}
+	return lines
+}
+
+// readFileTail returns up to max bytes from the end of path, or "" when the
+// file cannot be read. MSI logs are UTF-16 on most systems; NUL bytes are
+// dropped so the text stays readable either way.
+func readFileTail(path string, max int64) string {
+	f, err := os.Open(path)
+	if err != nil {
+		return ""
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return ""
+	}
+	if info.Size() > max {
+		if _, err := f.Seek(info.Size()-max, io.SeekStart); err != nil {
+			return ""
+		}
+	}
+	data, err := io.ReadAll(io.LimitReader(f, max))
+	if err != nil {
+		return ""
+	}
+	return strings.ReplaceAll(string(data), "\x00", "")
+}
@@ -19,6 +19,7 @@ import (
 	"syscall"
 	"time"
 
+	"printmaster/agent/featureflags"
 	"printmaster/common/logger"
 	"printmaster/common/updatepolicy"
 
@@ -46,6 +47,7 @@ type PendingUpdateState struct {
 	FromVersion   string    `json:"from_version"`
 	StartedAt     time.Time `json:"started_at"`
 	Method        string    `json:"method"` // "binary", "msi", "dnf", "apt", "yum"
+	RunID         string    `json:"run_id,omitempty"`
 }
 
 // ProgressCallback is invoked when update status changes, allowing real-time
@@ -363,6 +365,9 @@ func (m *Manager) ForceInstallLatest(ctx context.Context, reason string) error {
 		}
 		return fmt.Errorf("auto-update disabled")
 	}
+	if !featureflags.FirmwareUpdatesEnabled() {
+		return fmt.Errorf("updates are disabled by the server")
+	}
 
 	if m.client == nil {
 		return fmt.Errorf("update client not configured")
@@ -477,6 +482,12 @@ func (m *Manager) performCheck(ctx context.Context) error {
 		}
 	}()
 
+	if !featureflags.FirmwareUpdatesEnabled() {
+		m.logDebug("Skipping update check - updates disabled by server feature flag")
+		m.setStatus(StatusIdle)
+		return nil
+	}
+
 	// Check if we're within maintenance window
 	if !m.isInMaintenanceWindow() {
 		m.logDebug("Skipping update check - outside maintenance window")
@@ -1334,6 +1345,11 @@ func (m *Manager) writePendingUpdateState(targetVersion, method string) error {
 		StartedAt:     m.clock(),
 		Method:        method,
 	}
+	m.mu.RLock()
+	if m.currentRun != nil {
+		state.RunID = m.currentRun.ID
+	}
+	m.mu.RUnlock()
 
 	data, err := json.Marshal(state)
 	if err != nil {
@@ -1389,8 +1405,10 @@ func (m *Manager) ValidatePostUpdate() (bool, string, error) {
 			"expected", state.TargetVersion,
 			"actual", m.currentVersion,
 			"from_version", state.FromVersion)
-		return true, state.FromVersion, fmt.Errorf("update validation failed: expected version %s but running %s",
+		err := fmt.Errorf("update validation failed: expected version %s but running %s",
 			state.TargetVersion, m.currentVersion)
+		m.reportValidationFailure(state, err)
+		return true, state.FromVersion, err
 	}
 
 	// Success!
@@ -1735,12 +1753,14 @@ func (m *Manager) reportTelemetry(ctx context.Context, status Status, errCode, e
 		Timestamp:      m.clock(),
 	}
 
+	var runStarted time.Time
 	m.mu.RLock()
 	if m.currentRun != nil {
 		payload.RunID = m.currentRun.ID
 		payload.TargetVersion = m.currentRun.TargetVersion
 		payload.SizeBytes = m.currentRun.SizeBytes
 		payload.DownloadTimeMs = m.currentRun.DownloadTimeMs
+		runStarted = m.currentRun.RequestedAt
 	}
 	m.mu.RUnlock()
 
@@ -1748,7 +1768,38 @@ func (m *Manager) reportTelemetry(ctx context.Context, status Status, errCode, e
 		payload.ErrorCode = errCode
 		payload.ErrorMessage = errMsg
 	}
+	if status == StatusFailed {
+		payload.Diagnostics = m.collectDiagnostics(runStarted)
+	}
+
+	m.sendTelemetry(payload)
+}
+
+// reportValidationFailure reports an update that was applied but did not
+// come back as the expected version after restart. The installer log left by
+// the previous process is the most useful diagnostic here.
+func (m *Manager) reportValidationFailure(state PendingUpdateState, err error) {
+	if m.telemetry == nil {
+		return
+	}
+	payload := TelemetryPayload{
+		RunID:          state.RunID,
+		Status:         StatusFailed,
+		CurrentVersion: m.currentVersion,
+		TargetVersion:  state.TargetVersion,
+		ErrorCode:      ErrCodeHealthCheck,
+		ErrorMessage:   err.Error(),
+		Timestamp:      m.clock(),
+		Metadata: map[string]any{
+			"from_version": state.FromVersion,
+			"method":       state.Method,
+		},
+		Diagnostics: m.collectDiagnostics(state.StartedAt),
+	}
+	m.sendTelemetry(payload)
+}
 
+func (m *Manager) sendTelemetry(payload TelemetryPayload) {
 	go func() {
 		if err := m.telemetry.ReportUpdateStatus(context.Background(), payload); err != nil {
 			m.logWarn("Failed to report telemetry", "error", err)
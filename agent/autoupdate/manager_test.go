@@ -8,9 +8,11 @@ import (
 	"os"
 	"path/filepath"
 	"runtime"
+	"strings"
 	"testing"
 	"time"
 
+	"printmaster/common/logger"
 	"printmaster/common/updatepolicy"
 )
 
@@ -345,3 +347,98 @@ func (m *forceInstallClient) DownloadArtifactWithProgress(ctx context.Context, m
 	}
 	return int64(len(m.payload)), nil
 }
+
+// recordingTelemetry captures telemetry payloads for inspection.
+type recordingTelemetry struct {
+	payloads chan TelemetryPayload
+}
+
+func (r *recordingTelemetry) ReportUpdateStatus(ctx context.Context, payload TelemetryPayload) error {
+	r.payloads <- payload
+	return nil
+}
+
+func TestValidatePostUpdateReportsFailureDiagnostics(t *testing.T) {
+	t.Parallel()
+
+	dataDir := t.TempDir()
+	log := logger.New(logger.INFO, "", 100)
+	log.SetConsoleOutput(false)
+	sink := &recordingTelemetry{payloads: make(chan TelemetryPayload, 1)}
+
+	manager, err := NewManager(Options{
+		Enabled:        true,
+		CurrentVersion: "1.0.0",
+		Platform:       "linux",
+		Arch:           "amd64",
+		DataDir:        dataDir,
+		ServerClient:   &mockUpdateClient{},
+		TelemetrySink:  sink,
+		Log:            log,
+	})
+	if err != nil {
+		t.Fatalf("NewManager() error = %v", err)
+	}
+
+	state := `{"target_version":"1.1.0","from_version":"1.0.0","started_at":"2020-01-01T00:00:00Z","method":"binary","run_id":"run-1"}`
+	if err := os.WriteFile(filepath.Join(manager.stateDir, pendingUpdateFile), []byte(state), 0o644); err != nil {
+		t.Fatalf("write pending state: %v", err)
+	}
+
+	updated, _, err := manager.ValidatePostUpdate()
+	if !updated || err == nil {
+		t.Fatalf("ValidatePostUpdate() = %v, %v; want updated with error", updated, err)
+	}
+
+	select {
+	case p := <-sink.payloads:
+		if p.Status != StatusFailed || p.RunID != "run-1" || p.TargetVersion != "1.1.0" {
+			t.Fatalf("unexpected payload: %+v", p)
+		}
+		if p.Diagnostics == nil {
+			t.Fatal("expected diagnostics on failure payload")
+		}
+		if p.Diagnostics.InstallMethod != "binary" || p.Diagnostics.OS != "linux" {
+			t.Errorf("unexpected diagnostics: %+v", p.Diagnostics)
+		}
+		found := false
+		for _, line := range p.Diagnostics.LogTail {
+			if strings.Contains(line, "Post-update validation FAILED") {
+				found = true
+			}
+		}
+		if !found {
+			t.Errorf("log tail missing validation failure: %v", p.Diagnostics.LogTail)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("no telemetry reported for validation failure")
+	}
+}
+
+func TestFormatLogTail(t *testing.T) {
+	t.Parallel()
+
+	base := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	entries := []logger.LogEntry{
+		{Timestamp: base.Add(-time.Minute), Level: logger.INFO, Message: "before run"},
+		{Timestamp: base, Level: logger.WARN, Message: "download attempt failed", Context: map[string]interface{}{"b": 2, "a": 1}},
+		{Timestamp: base.Add(time.Second), Level: logger.ERROR, Message: "giving up"},
+		{Timestamp: base.Add(2 * time.Second), Level: logger.INFO, Message: strings.Repeat("x", 2000)},
+	}
+
+	lines := formatLogTail(entries, base, 2)
+	if len(lines) != 2 {
+		t.Fatalf("len(lines) = %d, want 2", len(lines))
+	}
+	if !strings.HasSuffix(lines[0], "[ERROR] giving up") {
+		t.Errorf("lines[0] = %q", lines[0])
+	}
+	if len(lines[1]) > updatepolicy.MaxDiagnosticLogLineLength+3 {
+		t.Errorf("long line not truncated: %d bytes", len(lines[1]))
+	}
+
+	lines = formatLogTail(entries, base, 10)
+	if len(lines) != 3 || !strings.HasSuffix(lines[0], "download attempt failed a=1 b=2") {
+		t.Errorf("unexpected lines: %v", lines)
+	}
+}
@@ -27,5 +27,6 @@ func (a *TelemetryAdapter) ReportUpdateStatus(ctx context.Context, payload Telem
 		payload.ErrorCode,
 		payload.ErrorMessage,
 		payload.Metadata,
+		payload.Diagnostics,
 	)
 }
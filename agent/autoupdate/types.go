@@ -108,6 +108,8 @@ type TelemetryPayload struct {
 	ErrorMessage   string         `json:"error_message,omitempty"`
 	Timestamp      time.Time      `json:"timestamp"`
 	Metadata       map[string]any `json:"metadata,omitempty"`
+	// Diagnostics is attached to failure reports only.
+	Diagnostics *updatepolicy.FailureDiagnostics `json:"diagnostics,omitempty"`
 }
 
 // ErrorCode constants for structured error reporting.
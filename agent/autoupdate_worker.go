@@ -249,6 +249,12 @@ func initAutoUpdateWorker(
 		return
 	}
 
+	// Register the server command handler first: commands other than updates
+	// (settings sync, restart) must work even if auto-update fails to start.
+	agent.SetCommandHandler(func(command string, data map[string]interface{}) {
+		handleServerCommand(ctx, command, data, log)
+	})
+
 	configProvider := &autoUpdateConfigProvider{cfg: agentCfg}
 	fleetProvider := &fleetPolicyProvider{} // Will be populated when fleet policy arrives
 
@@ -273,12 +279,7 @@ func initAutoUpdateWorker(
 		autoUpdateManagerMu.Lock()
 		autoUpdateManager = manager
 		autoUpdateManagerMu.Unlock()
-
-		// Register command handler for server-triggered updates
-		agent.SetCommandHandler(func(command string, data map[string]interface{}) {
-			handleServerCommand(ctx, command, data, log)
-		})
-		log.Info("Auto-update ready: WebSocket command handler registered")
+		log.Info("Auto-update ready")
 	}
 }
 
@@ -350,6 +351,17 @@ func handleServerCommand(ctx context.Context, command string, data map[string]in
 			}
 		}()
 
+	case "sync_settings":
+		uploadWorkerMu.RLock()
+		worker := uploadWorker
+		uploadWorkerMu.RUnlock()
+		if worker == nil {
+			log.Warn("Received sync_settings command but upload worker not available")
+			return
+		}
+		log.Info("Syncing settings per server request")
+		go worker.syncSettings()
+
 	case "restart":
 		log.Info("Received restart command from server")
 		go func() {
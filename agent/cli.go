@@ -0,0 +1,734 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"printmaster/agent/agent"
+	"printmaster/agent/storage"
+	"printmaster/common/config"
+	"printmaster/common/logger"
+)
+
+// Exit codes for CLI subcommands.
+const (
+	cliExitOK    = 0
+	cliExitError = 1
+	cliExitUsage = 2
+)
+
+// cliCommands maps subcommand names to their implementations. Subcommands
+// reuse the scanner and storage layers without starting the web server, so
+// they can be scripted or run from cron next to (or instead of) the service.
+var cliCommands = map[string]func(args []string, stdout, stderr io.Writer) int{
+	"scan":          runScanCommand,
+	"collect":       runCollectCommand,
+	"export":        runExportCommand,
+	"hash-password": runHashPasswordCommand,
+	"kiosk-token":   runKioskTokenCommand,
+}
+
+// runCLICommand runs the subcommand named by args[0], if any. It reports
+// whether args named a subcommand and the process exit code.
+func runCLICommand(args []string) (bool, int) {
+	if len(args) == 0 {
+		return false, cliExitOK
+	}
+	if scanArgs, ok, err := scanFlagArgs(args); ok {
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%v\nUsage: printmaster-agent --scan CIDR[,CIDR...] [scan flags]\n", err)
+			return true, cliExitUsage
+		}
+		return true, runScanCommand(scanArgs, os.Stdout, os.Stderr)
+	}
+	cmd, ok := cliCommands[args[0]]
+	if !ok {
+		return false, cliExitOK
+	}
+	return true, cmd(args[1:], os.Stdout, os.Stderr)
+}
+
+// scanFlagArgs turns the one-shot form
+//
+//	printmaster-agent --scan 10.0.0.0/24 --format json
+//
+// into arguments for the scan subcommand. The ranges become --range values
+// and --no-store is implied, so nothing is written to disk. It reports false
+// when args have no --scan flag.
+func scanFlagArgs(args []string) ([]string, bool, error) {
+	for i, arg := range args {
+		name, value, hasValue := strings.Cut(strings.TrimLeft(arg, "-"), "=")
+		if !strings.HasPrefix(arg, "-") || name != "scan" {
+			continue
+		}
+		rest := append([]string{}, args[:i]...)
+		if !hasValue && i+1 < len(args) && !strings.HasPrefix(args[i+1], "-") {
+			value = args[i+1]
+			rest = append(rest, args[i+2:]...)
+		} else {
+			rest = append(rest, args[i+1:]...)
+		}
+		if strings.TrimSpace(value) == "" {
+			return nil, true, fmt.Errorf("--scan needs at least one range")
+		}
+		return append([]string{"--no-store", "--range", value}, rest...), true, nil
+	}
+	return nil, false, nil
+}
+
+// cliOptions holds the flags shared by every subcommand.
+type cliOptions struct {
+	configPath string
+	dbPath     string
+	format     string
+	outFile    string
+	verbose    bool
+
+	// ephemeral opens no device database and creates no files; agent
+	// settings are read only when the agent has already saved some.
+	ephemeral bool
+}
+
+func newCLIFlagSet(name, usage string, stderr io.Writer, opts *cliOptions, defaultFormat string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.StringVar(&opts.configPath, "config", "config.toml", "Configuration file path")
+	fs.StringVar(&opts.dbPath, "db", "", "Device database path (default: from config or the agent data directory)")
+	fs.StringVar(&opts.format, "output", defaultFormat, "Output format: json, csv or table")
+	fs.StringVar(&opts.format, "format", defaultFormat, "Alias for --output")
+	fs.StringVar(&opts.outFile, "file", "", "Write output to this file instead of stdout")
+	fs.BoolVar(&opts.verbose, "verbose", false, "Write agent logs to stderr")
+	fs.Usage = func() {
+		fmt.Fprintf(stderr, "Usage: printmaster-agent %s\n\nFlags:\n", usage)
+		fs.PrintDefaults()
+	}
+	return fs
+}
+
+func validCLIFormat(format string) bool {
+	switch format {
+	case "json", "csv", "table":
+		return true
+	}
+	return false
+}
+
+// cliEnv is the agent state a subcommand runs against.
+type cliEnv struct {
+	ctx    context.Context
+	cancel context.CancelFunc
+	cfg    *AgentConfig
+}
+
+// openCLIEnv loads configuration and opens the agent databases the same way
+// an interactive agent does. Logs go nowhere unless verbose is set, in which
+// case they are written to stderr so stdout stays machine readable.
+func openCLIEnv(opts *cliOptions, stderr io.Writer) (*cliEnv, error) {
+	level := logger.WARN
+	if opts.verbose {
+		level = logger.INFO
+	}
+	appLogger = logger.New(level, "", 100)
+	appLogger.SetConsoleOutput(false)
+	if opts.verbose {
+		appLogger.SetOnLogCallback(func(entry logger.LogEntry) {
+			fmt.Fprintf(stderr, "%s [%s] %s %v\n", entry.Timestamp.Format(time.RFC3339), logger.LevelToString(entry.Level), entry.Message, entry.Context)
+		})
+	}
+	logger.SetGlobal(appLogger)
+	agent.SetLogger(appLogger)
+	storage.SetLogger(appLogger)
+
+	cfg := loadCLIAgentConfig(opts.configPath)
+	if opts.ephemeral {
+		if err := openCLISettingsIfPresent(cfg, opts.dbPath); err != nil {
+			return nil, err
+		}
+	} else if err := openCLIDatabases(cfg, opts.dbPath); err != nil {
+		return nil, err
+	}
+	if agentConfigStore != nil {
+		settingsManager = NewSettingsManager(agentConfigStore)
+		loadUnifiedSettings(agentConfigStore)
+	}
+	if disc := cliDiscoverySettings(); disc != nil {
+		applyPersistFilterSettings(disc)
+		applyScanScopeSettings(disc)
+	}
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt)
+	return &cliEnv{ctx: ctx, cancel: cancel, cfg: cfg}, nil
+}
+
+// openCLIDatabases opens (creating if needed) the agent config and device
+// databases.
+func openCLIDatabases(cfg *AgentConfig, dbOverride string) error {
+	dbPath, err := resolveCLIDatabasePath(cfg, dbOverride)
+	if err != nil {
+		return err
+	}
+
+	if err := ConfigureDatabaseEncryption(cfg.DatabaseEncryption, filepath.Dir(dbPath)); err != nil {
+		return err
+	}
+
+	agentDBPath := filepath.Join(filepath.Dir(dbPath), "agent.db")
+	agentConfigStore, err = storage.NewAgentConfigStore(agentDBPath)
+	if err != nil {
+		return fmt.Errorf("open agent config database %s: %w", agentDBPath, err)
+	}
+	deviceStore, err = storage.NewSQLiteStoreWithConfig(dbPath, agentConfigStore)
+	if err != nil {
+		agentConfigStore.Close()
+		agentConfigStore = nil
+		return fmt.Errorf("open device database %s: %w", dbPath, err)
+	}
+	return nil
+}
+
+// openCLISettingsIfPresent opens the agent config database only when it
+// already exists, so ephemeral commands still honor the saved scan scope and
+// discovery settings without leaving files behind on a fresh machine. The
+// database is opened read-only: no key is generated and a plaintext database
+// is never migrated to (or removed in favour of) a sealed image.
+func openCLISettingsIfPresent(cfg *AgentConfig, dbOverride string) error {
+	dbPath, err := cliDatabasePath(cfg, dbOverride)
+	if err != nil {
+		return err
+	}
+	key, err := readDatabaseEncryptionKey(cfg.DatabaseEncryption, filepath.Dir(dbPath))
+	if err != nil {
+		return err
+	}
+	agentDBPath := filepath.Join(filepath.Dir(dbPath), "agent.db")
+	store, err := storage.OpenAgentConfigReadOnly(agentDBPath, key)
+	if errors.Is(err, storage.ErrNotFound) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("open agent config database %s: %w", agentDBPath, err)
+	}
+	agentConfigStore = store
+	return nil
+}
+
+func (e *cliEnv) Close() {
+	e.cancel()
+	if deviceStore != nil {
+		deviceStore.Close()
+	}
+	if agentConfigStore != nil {
+		agentConfigStore.Close()
+	}
+}
+
+// loadCLIAgentConfig resolves config.toml like runInteractive: explicit
+// flag/env path first, then the executable directory and working directory.
+func loadCLIAgentConfig(configFlag string) *AgentConfig {
+	paths := []string{}
+	if resolved := config.ResolveConfigPath("AGENT", configFlag); resolved != "" {
+		paths = append(paths, resolved)
+	}
+	paths = append(paths, filepath.Join(filepath.Dir(os.Args[0]), "config.toml"), "config.toml")
+	for _, p := range paths {
+		if _, err := os.Stat(p); err != nil {
+			continue
+		}
+		if cfg, err := LoadAgentConfig(p); err == nil {
+			return cfg
+		}
+	}
+	cfg := DefaultAgentConfig()
+	ApplyEnvironmentOverrides(cfg)
+	return cfg
+}
+
+// resolveCLIDatabasePath picks the device database: --db, then the config
+// (including AGENT_DB_PATH), then the interactive agent data directory.
+func resolveCLIDatabasePath(cfg *AgentConfig, override string) (string, error) {
+	dbPath, err := cliDatabasePath(cfg, override)
+	if err != nil {
+		return "", err
+	}
+	if err := os.MkdirAll(filepath.Dir(dbPath), 0755); err != nil {
+		return "", fmt.Errorf("create database directory: %w", err)
+	}
+	return dbPath, nil
+}
+
+// cliDatabasePath is resolveCLIDatabasePath without creating the directory.
+func cliDatabasePath(cfg *AgentConfig, override string) (string, error) {
+	dbPath := strings.TrimSpace(override)
+	if dbPath == "" {
+		config.ApplyDatabaseEnvOverrides(&cfg.Database, "AGENT")
+		dbPath = cfg.Database.Path
+	}
+	if dbPath == "" {
+		dataDir, err := config.GetDataDirectory("agent", false)
+		if err != nil {
+			return "", fmt.Errorf("locate agent data directory: %w", err)
+		}
+		return filepath.Join(dataDir, "devices.db"), nil
+	}
+	if fi, err := os.Stat(dbPath); err == nil && fi.IsDir() {
+		dbPath = filepath.Join(dbPath, "devices.db")
+	}
+	return dbPath, nil
+}
+
+func cliDiscoverySettings() map[string]interface{} {
+	if agentConfigStore == nil {
+		return nil
+	}
+	var disc map[string]interface{}
+	if err := agentConfigStore.GetConfigValue("discovery_settings", &disc); err != nil {
+		return nil
+	}
+	return disc
+}
+
+// stringList is a repeatable, comma-separated flag value.
+type stringList []string
+
+func (s *stringList) String() string { return strings.Join(*s, ",") }
+
+func (s *stringList) Set(v string) error {
+	for _, part := range strings.Split(v, ",") {
+		if part = strings.TrimSpace(part); part != "" {
+			*s = append(*s, part)
+		}
+	}
+	return nil
+}
+
+// runScanCommand runs a discovery scan and prints the printers found:
+//
+//	printmaster-agent scan --range 10.0.0.0/24 --output json
+func runScanCommand(args []string, stdout, stderr io.Writer) int {
+	var opts cliOptions
+	var ranges stringList
+	fs := newCLIFlagSet("scan", "scan [--range CIDR|A-B|IP]... [flags]", stderr, &opts, "table")
+	fs.Var(&ranges, "range", "Range to scan (repeatable or comma-separated; default: saved ranges or the local subnet)")
+	mode := fs.String("mode", "full", "Discovery mode: full (SNMP walk) or quick (TCP probe only)")
+	timeout := fs.Int("timeout", 5, "SNMP timeout in seconds")
+	concurrency := fs.Int("concurrency", 0, "Concurrent probes (default: discovery setting or 50)")
+	noStore := fs.Bool("no-store", false, "Do not open the device database or save anything")
+	if err := fs.Parse(args); err != nil {
+		return cliExitUsage
+	}
+	opts.ephemeral = *noStore
+	if !validCLIFormat(opts.format) {
+		fmt.Fprintf(stderr, "unknown output format %q\n", opts.format)
+		return cliExitUsage
+	}
+	if *mode != "full" && *mode != "quick" {
+		fmt.Fprintf(stderr, "unknown mode %q (use full or quick)\n", *mode)
+		return cliExitUsage
+	}
+
+	env, err := openCLIEnv(&opts, stderr)
+	if err != nil {
+		fmt.Fprintf(stderr, "scan: %v\n", err)
+		return cliExitError
+	}
+	defer env.Close()
+
+	disc := cliDiscoverySettings()
+	if len(ranges) == 0 && disc["manual_ranges"] == true {
+		if saved, err := agentConfigStore.GetRangesList(); err == nil {
+			ranges = saved
+		}
+	}
+	conc := *concurrency
+	if conc <= 0 {
+		if v, ok := disc["concurrency"].(float64); ok && v > 0 {
+			conc = int(v)
+		}
+	}
+	discoveryCfg := &agent.DiscoveryConfig{TCPEnabled: true, SNMPEnabled: true}
+	if disc != nil {
+		discoveryCfg.ARPEnabled = disc["arp_enabled"] == true
+		discoveryCfg.ICMPEnabled = disc["icmp_enabled"] == true
+		discoveryCfg.MDNSEnabled = disc["mdns_enabled"] == true
+		if v, ok := disc["tcp_enabled"].(bool); ok {
+			discoveryCfg.TCPEnabled = v
+		}
+		if v, ok := disc["snmp_enabled"].(bool); ok {
+			discoveryCfg.SNMPEnabled = v
+		}
+	}
+	if !*noStore {
+		agent.SetDeviceStorage(&deviceStorageAdapter{store: deviceStore})
+	}
+
+	scanStart := time.Now()
+	printers, err := Discover(env.ctx, ranges, *mode, discoveryCfg, deviceStore, conc, *timeout)
+	if !*noStore {
+		recordScanRun(scanRunSourceCLI, *mode, ranges, scanStart, printers, err)
+	}
+	if err != nil {
+		fmt.Fprintf(stderr, "scan: %v\n", err)
+		return cliExitError
+	}
+	if printers == nil {
+		printers = []agent.PrinterInfo{}
+	}
+
+	header := []string{"ip", "serial", "manufacturer", "model", "mac_address", "location", "snmp", "page_count", "status"}
+	rows := make([][]string, 0, len(printers))
+	for _, pi := range printers {
+		rows = append(rows, []string{pi.IP, pi.Serial, pi.Manufacturer, pi.Model, pi.MAC, pi.Location, pi.SNMPVersion, cliInt(pi.PageCount), strings.Join(pi.StatusMessages, "; ")})
+	}
+	return writeCLIOutput(&opts, stdout, stderr, printers, header, rows)
+}
+
+// runCollectCommand collects a metrics snapshot from one device (or all of
+// them) and saves it like the scheduled metrics rescan does:
+//
+//	printmaster-agent collect --serial X
+func runCollectCommand(args []string, stdout, stderr io.Writer) int {
+	var opts cliOptions
+	fs := newCLIFlagSet("collect", "collect (--serial SERIAL | --ip IP | --all) [flags]", stderr, &opts, "table")
+	serial := fs.String("serial", "", "Serial number of a known device")
+	ip := fs.String("ip", "", "IP address of a known device")
+	all := fs.Bool("all", false, "Collect from every known device")
+	timeout := fs.Int("timeout", 10, "SNMP timeout in seconds")
+	noStore := fs.Bool("no-store", false, "Do not save the snapshot to the device database")
+	if err := fs.Parse(args); err != nil {
+		return cliExitUsage
+	}
+	if !validCLIFormat(opts.format) {
+		fmt.Fprintf(stderr, "unknown output format %q\n", opts.format)
+		return cliExitUsage
+	}
+	selectors := 0
+	for _, set := range []bool{*serial != "", *ip != "", *all} {
+		if set {
+			selectors++
+		}
+	}
+	if selectors != 1 {
+		fmt.Fprintln(stderr, "collect: specify exactly one of --serial, --ip or --all")
+		fs.Usage()
+		return cliExitUsage
+	}
+
+	env, err := openCLIEnv(&opts, stderr)
+	if err != nil {
+		fmt.Fprintf(stderr, "collect: %v\n", err)
+		return cliExitError
+	}
+	defer env.Close()
+
+	filter := storage.DeviceFilter{Serial: strings.TrimSpace(*serial), IP: strings.TrimSpace(*ip)}
+	devices, err := deviceStore.List(env.ctx, filter)
+	if err != nil {
+		fmt.Fprintf(stderr, "collect: list devices: %v\n", err)
+		return cliExitError
+	}
+	if len(devices) == 0 {
+		fmt.Fprintln(stderr, "collect: no matching device in the database (run scan first)")
+		return cliExitError
+	}
+
+	exit := cliExitOK
+	snapshots := make([]*storage.MetricsSnapshot, 0, len(devices))
+	for _, device := range devices {
+		pi := storage.DeviceToPrinterInfo(device)
+		agentSnapshot, err := CollectMetricsWithOIDs(env.ctx, device.IP, device.Serial, device.Manufacturer, *timeout, &pi.LearnedOIDs)
+		if err != nil {
+			fmt.Fprintf(stderr, "collect: %s (%s): %v\n", device.Serial, device.IP, err)
+			exit = cliExitError
+			continue
+		}
+		snapshot := metricsSnapshotFromAgent(agentSnapshot)
+		if snapshot.Serial == "" {
+			snapshot.Serial = device.Serial
+		}
+		snapshot.Timestamp = time.Now().UTC()
+		if !*noStore {
+			if err := deviceStore.SaveMetricsSnapshot(env.ctx, snapshot); err != nil {
+				fmt.Fprintf(stderr, "collect: save %s: %v\n", device.Serial, err)
+				exit = cliExitError
+			}
+		}
+		snapshots = append(snapshots, snapshot)
+	}
+
+	if code := writeCLIOutput(&opts, stdout, stderr, snapshots, metricsHeader, metricsRows(snapshots, devices)); code != cliExitOK {
+		return code
+	}
+	return exit
+}
+
+// runExportCommand writes devices or their latest metrics from the local
+// database:
+//
+//	printmaster-agent export devices --format csv
+func runExportCommand(args []string, stdout, stderr io.Writer) int {
+	var opts cliOptions
+	fs := newCLIFlagSet("export", "export (devices|metrics) [flags]", stderr, &opts, "csv")
+	savedOnly := fs.Bool("saved", false, "Only export saved devices")
+	if len(args) == 0 || strings.HasPrefix(args[0], "-") {
+		fs.Usage()
+		return cliExitUsage
+	}
+	kind := args[0]
+	if err := fs.Parse(args[1:]); err != nil {
+		return cliExitUsage
+	}
+	if kind != "devices" && kind != "metrics" {
+		fmt.Fprintf(stderr, "export: unknown kind %q (use devices or metrics)\n", kind)
+		return cliExitUsage
+	}
+	if !validCLIFormat(opts.format) {
+		fmt.Fprintf(stderr, "unknown output format %q\n", opts.format)
+		return cliExitUsage
+	}
+
+	env, err := openCLIEnv(&opts, stderr)
+	if err != nil {
+		fmt.Fprintf(stderr, "export: %v\n", err)
+		return cliExitError
+	}
+	defer env.Close()
+
+	visible := true
+	filter := storage.DeviceFilter{Visible: &visible}
+	if *savedOnly {
+		saved := true
+		filter.IsSaved = &saved
+	}
+	devices, err := deviceStore.List(env.ctx, filter)
+	if err != nil {
+		fmt.Fprintf(stderr, "export: list devices: %v\n", err)
+		return cliExitError
+	}
+	if devices == nil {
+		devices = []*storage.Device{}
+	}
+
+	if kind == "metrics" {
+		snapshots := make([]*storage.MetricsSnapshot, 0, len(devices))
+		for _, device := range devices {
+			snapshot, err := deviceStore.GetLatestMetrics(env.ctx, device.Serial)
+			if err != nil || snapshot == nil {
+				continue
+			}
+			snapshots = append(snapshots, snapshot)
+		}
+		return writeCLIOutput(&opts, stdout, stderr, snapshots, metricsHeader, metricsRows(snapshots, devices))
+	}
+
+	header := []string{"serial", "ip", "manufacturer", "model", "hostname", "mac_address", "firmware", "location", "asset_number", "saved", "first_seen", "last_seen"}
+	rows := make([][]string, 0, len(devices))
+	for _, d := range devices {
+		rows = append(rows, []string{d.Serial, d.IP, d.Manufacturer, d.Model, d.Hostname, d.MACAddress, d.Firmware, d.Location, d.AssetNumber,
+			strconv.FormatBool(d.IsSaved), cliTime(d.FirstSeen), cliTime(d.LastSeen)})
+	}
+	return writeCLIOutput(&opts, stdout, stderr, devices, header, rows)
+}
+
+// runHashPasswordCommand prints a password hash for a local UI account
+// ([[web.auth.users]] password_hash). The password is read from the first
+// line of stdin so it does not end up in shell history:
+//
+//	printmaster-agent hash-password < password.txt
+func runHashPasswordCommand(args []string, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("hash-password", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.Usage = func() {
+		fmt.Fprintln(stderr, "Usage: printmaster-agent hash-password < password")
+		fmt.Fprintln(stderr, "\nReads a password from stdin and prints a password_hash for [[web.auth.users]].")
+	}
+	if err := fs.Parse(args); err != nil || fs.NArg() > 0 {
+		fs.Usage()
+		return cliExitUsage
+	}
+	line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil && !errors.Is(err, io.EOF) {
+		fmt.Fprintf(stderr, "hash-password: %v\n", err)
+		return cliExitError
+	}
+	password := strings.TrimRight(line, "\r\n")
+	if password == "" {
+		fmt.Fprintln(stderr, "hash-password: empty password")
+		return cliExitUsage
+	}
+	hash, err := hashAgentPassword(password)
+	if err != nil {
+		fmt.Fprintf(stderr, "hash-password: %v\n", err)
+		return cliExitError
+	}
+	fmt.Fprintln(stdout, hash)
+	return cliExitOK
+}
+
+// runKioskTokenCommand generates a kiosk token and prints it with the
+// [[web.auth.kiosk_tokens]] entry to paste into the config. Only the hash is
+// stored, so the token is shown once:
+//
+//	printmaster-agent kiosk-token -name lobby -scopes devices,events -expires 2027-06-30
+func runKioskTokenCommand(args []string, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("kiosk-token", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	name := fs.String("name", "kiosk", "Name shown in logs for requests made with the token")
+	scopes := fs.String("scopes", strings.Join(kioskDefaultScopes, ","), "Comma-separated scopes: devices, metrics, events")
+	expires := fs.String("expires", "", "Expiry as an RFC 3339 time or date (empty: never)")
+	fs.Usage = func() {
+		fmt.Fprintln(stderr, "Usage: printmaster-agent kiosk-token [-name N] [-scopes devices,metrics,events] [-expires DATE]")
+		fmt.Fprintln(stderr, "\nGenerates a read-only token for embedded dashboards and prints its config entry.")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil || fs.NArg() > 0 {
+		fs.Usage()
+		return cliExitUsage
+	}
+	var scopeList []string
+	for _, scope := range strings.Split(*scopes, ",") {
+		scope = strings.ToLower(strings.TrimSpace(scope))
+		if scope == "" {
+			continue
+		}
+		if _, ok := kioskScopeRoutes[scope]; !ok {
+			fmt.Fprintf(stderr, "kiosk-token: unknown scope %q\n", scope)
+			return cliExitUsage
+		}
+		scopeList = append(scopeList, strconv.Quote(scope))
+	}
+	if *expires != "" {
+		if _, err := parseKioskExpiry(*expires); err != nil {
+			fmt.Fprintf(stderr, "kiosk-token: invalid -expires %q\n", *expires)
+			return cliExitUsage
+		}
+	}
+	token, err := newKioskToken()
+	if err != nil {
+		fmt.Fprintf(stderr, "kiosk-token: %v\n", err)
+		return cliExitError
+	}
+	fmt.Fprintf(stdout, "Token (shown once): %s\n\n", token)
+	fmt.Fprintln(stdout, "[[web.auth.kiosk_tokens]]")
+	fmt.Fprintf(stdout, "  name = %s\n", strconv.Quote(*name))
+	fmt.Fprintf(stdout, "  token_hash = %s\n", strconv.Quote(hashKioskToken(token)))
+	fmt.Fprintf(stdout, "  scopes = [%s]\n", strings.Join(scopeList, ", "))
+	if *expires != "" {
+		fmt.Fprintf(stdout, "  expires_at = %s\n", strconv.Quote(*expires))
+	}
+	return cliExitOK
+}
+
+var metricsHeader = []string{"serial", "ip", "timestamp", "page_count", "mono_pages", "color_pages", "scan_count", "copy_pages", "fax_pages"}
+
+func metricsRows(snapshots []*storage.MetricsSnapshot, devices []*storage.Device) [][]string {
+	ips := make(map[string]string, len(devices))
+	for _, d := range devices {
+		ips[d.Serial] = d.IP
+	}
+	rows := make([][]string, 0, len(snapshots))
+	for _, s := range snapshots {
+		rows = append(rows, []string{s.Serial, ips[s.Serial], cliTime(s.Timestamp), cliInt(s.PageCount), cliInt(s.MonoPages),
+			cliInt(s.ColorPages), cliInt(s.ScanCount), cliInt(s.CopyPages), cliInt(s.FaxPages)})
+	}
+	return rows
+}
+
+// metricsSnapshotFromAgent converts a collected snapshot to its storage form.
+func metricsSnapshotFromAgent(agentSnapshot *agent.DeviceMetricsSnapshot) *storage.MetricsSnapshot {
+	snapshot := &storage.MetricsSnapshot{}
+	snapshot.Serial = agentSnapshot.Serial
+	snapshot.PageCount = agentSnapshot.PageCount
+	snapshot.ColorPages = agentSnapshot.ColorPages
+	snapshot.MonoPages = agentSnapshot.MonoPages
+	snapshot.ScanCount = agentSnapshot.ScanCount
+	snapshot.TonerLevels = agentSnapshot.TonerLevels
+	snapshot.FaxPages = agentSnapshot.FaxPages
+	snapshot.CopyPages = agentSnapshot.CopyPages
+	snapshot.OtherPages = agentSnapshot.OtherPages
+	snapshot.CopyMonoPages = agentSnapshot.CopyMonoPages
+	snapshot.CopyFlatbedScans = agentSnapshot.CopyFlatbedScans
+	snapshot.CopyADFScans = agentSnapshot.CopyADFScans
+	snapshot.FaxFlatbedScans = agentSnapshot.FaxFlatbedScans
+	snapshot.FaxADFScans = agentSnapshot.FaxADFScans
+	snapshot.ScanToHostFlatbed = agentSnapshot.ScanToHostFlatbed
+	snapshot.ScanToHostADF = agentSnapshot.ScanToHostADF
+	snapshot.DuplexSheets = agentSnapshot.DuplexSheets
+	snapshot.SimplexSheets = agentSnapshot.SimplexSheets
+	snapshot.NUpSheets = agentSnapshot.NUpSheets
+	snapshot.JamEvents = agentSnapshot.JamEvents
+	snapshot.ScannerJamEvents = agentSnapshot.ScannerJamEvents
+	return snapshot
+}
+
+// writeCLIOutput writes value as JSON, or header and rows as CSV or an
+// aligned table, to --file or stdout.
+func writeCLIOutput(opts *cliOptions, stdout, stderr io.Writer, value interface{}, header []string, rows [][]string) int {
+	w := stdout
+	if opts.outFile != "" {
+		f, err := os.Create(opts.outFile)
+		if err != nil {
+			fmt.Fprintf(stderr, "create %s: %v\n", opts.outFile, err)
+			return cliExitError
+		}
+		defer f.Close()
+		w = f
+	}
+	if err := writeRecords(w, opts.format, value, header, rows); err != nil {
+		fmt.Fprintf(stderr, "write output: %v\n", err)
+		return cliExitError
+	}
+	return cliExitOK
+}
+
+func writeRecords(w io.Writer, format string, value interface{}, header []string, rows [][]string) error {
+	switch format {
+	case "json":
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(value)
+	case "csv":
+		cw := csv.NewWriter(w)
+		if err := cw.Write(header); err != nil {
+			return err
+		}
+		if err := cw.WriteAll(rows); err != nil {
+			return err
+		}
+		return cw.Error()
+	case "table":
+		tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(tw, strings.ToUpper(strings.Join(header, "\t")))
+		for _, row := range rows {
+			fmt.Fprintln(tw, strings.Join(row, "\t"))
+		}
+		return tw.Flush()
+	}
+	return errors.New("unknown format " + format)
+}
+
+func cliInt(n int) string {
+	if n == 0 {
+		return ""
+	}
+	return strconv.Itoa(n)
+}
+
+func cliTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.UTC().Format(time.RFC3339)
+}
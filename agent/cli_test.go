@@ -0,0 +1,109 @@
+package main
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestRunCLICommandIgnoresFlags(t *testing.T) {
+	t.Parallel()
+	for _, args := range [][]string{nil, {"--service", "install"}, {"-version"}, {"unknown"}} {
+		if handled, _ := runCLICommand(args); handled {
+			t.Fatalf("runCLICommand(%v) should not handle non-subcommands", args)
+		}
+	}
+}
+
+func TestScanFlagArgs(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		args []string
+		want []string
+		ok   bool
+		err  bool
+	}{
+		{[]string{"--scan", "10.0.0.0/24", "--format", "json"}, []string{"--no-store", "--range", "10.0.0.0/24", "--format", "json"}, true, false},
+		{[]string{"--format", "csv", "-scan=10.0.0.0/24,10.0.1.5"}, []string{"--no-store", "--range", "10.0.0.0/24,10.0.1.5", "--format", "csv"}, true, false},
+		{[]string{"--scan", "--format", "json"}, nil, true, true},
+		{[]string{"--scan="}, nil, true, true},
+		{[]string{"--service", "install"}, nil, false, false},
+		{[]string{"scan", "--range", "10.0.0.1"}, nil, false, false},
+	}
+	for _, tt := range tests {
+		got, ok, err := scanFlagArgs(tt.args)
+		if ok != tt.ok || (err != nil) != tt.err || !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("scanFlagArgs(%v) = %v, %v, %v; want %v, %v, err=%v", tt.args, got, ok, err, tt.want, tt.ok, tt.err)
+		}
+	}
+}
+
+func TestStringListSplitsCommas(t *testing.T) {
+	t.Parallel()
+	var ranges stringList
+	_ = ranges.Set("10.0.0.0/24, 10.0.1.5")
+	_ = ranges.Set("192.168.1.10-192.168.1.20")
+	want := stringList{"10.0.0.0/24", "10.0.1.5", "192.168.1.10-192.168.1.20"}
+	if !reflect.DeepEqual(ranges, want) {
+		t.Fatalf("ranges = %v, want %v", ranges, want)
+	}
+}
+
+func TestWriteRecordsFormats(t *testing.T) {
+	t.Parallel()
+	header := []string{"serial", "ip"}
+	rows := [][]string{{"SN1", "10.0.0.5"}, {"SN,2", "10.0.0.6"}}
+	value := []map[string]string{{"serial": "SN1"}}
+
+	var buf bytes.Buffer
+	if err := writeRecords(&buf, "csv", value, header, rows); err != nil {
+		t.Fatal(err)
+	}
+	if got := buf.String(); got != "serial,ip\nSN1,10.0.0.5\n\"SN,2\",10.0.0.6\n" {
+		t.Fatalf("unexpected csv:\n%s", got)
+	}
+
+	buf.Reset()
+	if err := writeRecords(&buf, "json", value, header, rows); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buf.String(), `"serial": "SN1"`) {
+		t.Fatalf("unexpected json:\n%s", buf.String())
+	}
+
+	buf.Reset()
+	if err := writeRecords(&buf, "table", value, header, rows); err != nil {
+		t.Fatal(err)
+	}
+	if lines := strings.Split(strings.TrimSpace(buf.String()), "\n"); len(lines) != 3 || !strings.HasPrefix(lines[0], "SERIAL") {
+		t.Fatalf("unexpected table:\n%s", buf.String())
+	}
+
+	if err := writeRecords(&buf, "xml", value, header, rows); err == nil {
+		t.Fatal("expected error for unknown format")
+	}
+}
+
+func TestCLICommandsRejectBadUsage(t *testing.T) {
+	t.Parallel()
+	cases := [][]string{
+		{"collect"},
+		{"collect", "--serial", "X", "--all"},
+		{"scan", "--output", "xml"},
+		{"scan", "--mode", "deep"},
+		{"export"},
+		{"export", "printers"},
+		{"kiosk-token", "-scopes", "settings"},
+		{"kiosk-token", "-expires", "next week"},
+	}
+	for _, args := range cases {
+		var stdout, stderr bytes.Buffer
+		if code := cliCommands[args[0]](args[1:], &stdout, &stderr); code != cliExitUsage {
+			t.Errorf("%v: exit code %d, want %d (stderr: %s)", args, code, cliExitUsage, stderr.String())
+		}
+		if stdout.Len() != 0 {
+			t.Errorf("%v: unexpected stdout %q", args, stdout.String())
+		}
+	}
+}
@@ -1,28 +1,39 @@
 package main
 
 import (
+	"crypto/ed25519"
 	"crypto/rand"
+	"encoding/base64"
 	"fmt"
 	"os"
 	"path/filepath"
 	"strconv"
 	"strings"
+	"time"
 
+	"printmaster/agent/netproxy"
+	"printmaster/agent/plugins"
+	"printmaster/agent/storage"
 	"printmaster/common/config"
 	"printmaster/common/updatepolicy"
+	commonutil "printmaster/common/util"
 )
 
 // AgentConfig represents the agent configuration
 type AgentConfig struct {
-	AssetIDRegex           string                 `toml:"asset_id_regex"`
-	Concurrency            int                    `toml:"discovery_concurrency"`
-	SNMP                   SNMPConfig             `toml:"snmp"`
-	Server                 ServerConnectionConfig `toml:"server"`
-	AutoUpdate             AutoUpdateConfig       `toml:"auto_update"`
-	Database               config.DatabaseConfig  `toml:"database"`
-	Logging                config.LoggingConfig   `toml:"logging"`
-	Web                    WebConfig              `toml:"web"`
-	EpsonRemoteModeEnabled bool                   `toml:"epson_remote_mode_enabled"`
+	AssetIDRegex           string                   `toml:"asset_id_regex"`
+	Concurrency            int                      `toml:"discovery_concurrency"`
+	SNMP                   SNMPConfig               `toml:"snmp"`
+	Server                 ServerConnectionConfig   `toml:"server"`
+	AutoUpdate             AutoUpdateConfig         `toml:"auto_update"`
+	Database               config.DatabaseConfig    `toml:"database"`
+	DatabaseEncryption     DatabaseEncryptionConfig `toml:"database_encryption"`
+	Logging                config.LoggingConfig     `toml:"logging"`
+	Web                    WebConfig                `toml:"web"`
+	Watchdog               WatchdogConfig           `toml:"watchdog"`
+	Plugins                PluginsConfig            `toml:"plugins"`
+	MDNS                   MDNSConfig               `toml:"mdns"`
+	EpsonRemoteModeEnabled bool                     `toml:"epson_remote_mode_enabled"`
 }
 
 // SNMPConfig holds SNMP client settings
@@ -55,15 +66,71 @@ type SNMPConfig struct {
 
 // ServerConnectionConfig holds server registration and upload settings
 type ServerConnectionConfig struct {
-	Enabled            bool   `toml:"enabled"`
-	URL                string `toml:"url"`
-	Name               string `toml:"name"` // User-friendly name (configurable)
+	Enabled            bool              `toml:"enabled"`
+	URL                string            `toml:"url"`
+	Name               string            `toml:"name"` // User-friendly name (configurable)
+	CAPath             string            `toml:"ca_path"`
+	InsecureSkipVerify bool              `toml:"insecure_skip_verify"` // Skip TLS verification (dev/testing only)
+	UploadInterval     int               `toml:"upload_interval_seconds"`
+	HeartbeatInterval  int               `toml:"heartbeat_interval_seconds"`
+	Token              string            `toml:"token"`    // Stored after registration
+	AgentID            string            `toml:"agent_id"` // Stable UUID (auto-generated, do not edit)
+	Proxy              ServerProxyConfig `toml:"proxy"`
+	// Transport selects how data reaches the server: "http" (REST plus
+	// WebSocket, the default) or "mqtt" (publish through a broker)
+	Transport string     `toml:"transport"`
+	MQTT      MQTTConfig `toml:"mqtt"`
+}
+
+// MQTTConfig configures the broker used when the server transport is "mqtt".
+type MQTTConfig struct {
+	// Broker URL: tcp://, ssl://, ws:// or wss://
+	Broker   string `toml:"broker"`
+	ClientID string `toml:"client_id"` // defaults to printmaster-agent-<agent_id>
+	Username string `toml:"username"`
+	Password string `toml:"password"`
+	// TopicPrefix roots the per-agent topics: <prefix>/agents/<agent_id>/...
+	TopicPrefix string `toml:"topic_prefix"`
+	QoS         int    `toml:"qos"`
+	// CleanSession drops the broker-side session on connect; leave false to
+	// keep subscriptions and queued commands across reconnects
+	CleanSession       bool   `toml:"clean_session"`
+	KeepAliveSeconds   int    `toml:"keepalive_seconds"`
 	CAPath             string `toml:"ca_path"`
-	InsecureSkipVerify bool   `toml:"insecure_skip_verify"` // Skip TLS verification (dev/testing only)
-	UploadInterval     int    `toml:"upload_interval_seconds"`
-	HeartbeatInterval  int    `toml:"heartbeat_interval_seconds"`
-	Token              string `toml:"token"`    // Stored after registration
-	AgentID            string `toml:"agent_id"` // Stable UUID (auto-generated, do not edit)
+	CertPath           string `toml:"cert_path"` // client certificate for mutual TLS
+	KeyPath            string `toml:"key_path"`
+	InsecureSkipVerify bool   `toml:"insecure_skip_verify"`
+}
+
+// usesMQTT reports whether the server transport is MQTT.
+func (c ServerConnectionConfig) usesMQTT() bool {
+	return strings.EqualFold(strings.TrimSpace(c.Transport), "mqtt")
+}
+
+// ServerProxyConfig configures the outbound HTTP proxy used for traffic to the
+// server. LAN devices are always contacted direct.
+type ServerProxyConfig struct {
+	URL      string `toml:"url"`
+	Username string `toml:"username"`
+	Password string `toml:"password"`
+	// NoProxy lists host names, IPs or CIDR ranges that bypass the proxy
+	NoProxy []string `toml:"no_proxy"`
+	// PACURL is an optional proxy auto-config file (URL or local path)
+	PACURL string `toml:"pac_url"`
+	// BypassLAN sends private, loopback and link-local destinations direct
+	BypassLAN bool `toml:"bypass_lan"`
+}
+
+// resolverConfig converts the TOML settings for the netproxy package.
+func (c ServerProxyConfig) resolverConfig() netproxy.Config {
+	return netproxy.Config{
+		URL:       c.URL,
+		Username:  c.Username,
+		Password:  c.Password,
+		NoProxy:   c.NoProxy,
+		PACURL:    c.PACURL,
+		BypassLAN: c.BypassLAN,
+	}
 }
 
 // AutoUpdateConfig captures agent-side override preferences that determine how
@@ -82,6 +149,86 @@ type WebConfig struct {
 	Auth      WebAuthConfig `toml:"auth"`
 }
 
+// WatchdogConfig controls detection and recovery of a hung agent.
+type WatchdogConfig struct {
+	// Enabled turns on the in-process watchdog, which restarts the agent when
+	// a scan or upload runs longer than StallTimeoutMinutes
+	Enabled bool `toml:"enabled"`
+	// ChildProcess runs the agent as a worker process under the service
+	// wrapper, which restarts it when its heartbeat stops (service mode only)
+	ChildProcess bool `toml:"child_process"`
+	// StallTimeoutMinutes is how long a single scan or upload may run
+	StallTimeoutMinutes int `toml:"stall_timeout_minutes"`
+	// CrashDir is where crash bundles are written (default: <log dir>/crash)
+	CrashDir string `toml:"crash_dir"`
+}
+
+// MDNSConfig controls advertising the agent web UI over mDNS/Bonjour
+// (_printmaster-agent._tcp) so technicians can find agents on a LAN.
+type MDNSConfig struct {
+	Advertise bool `toml:"advertise"`
+	// InstanceName is the advertised service name (default: hostname)
+	InstanceName string `toml:"instance_name"`
+}
+
+// PluginsConfig lists the enrichment plugins run after discovery and metrics
+// collection. Only plugins listed here run; they cannot be added from the web
+// UI or the server.
+type PluginsConfig struct {
+	Enabled bool `toml:"enabled"`
+	// WASMRuntime runs .wasm plugins as `<runtime> run <module>` (default: wasmtime)
+	WASMRuntime string `toml:"wasm_runtime"`
+	// MaxConcurrent bounds plugin processes running at once (default 4)
+	MaxConcurrent int                     `toml:"max_concurrent"`
+	Enrichment    []EnrichmentPluginEntry `toml:"enrichment"`
+}
+
+// EnrichmentPluginEntry is one allowlisted plugin.
+type EnrichmentPluginEntry struct {
+	Name string `toml:"name"`
+	// Path is the absolute path to the executable or .wasm module
+	Path string   `toml:"path"`
+	Args []string `toml:"args"`
+	// SHA256 pins the file; a changed file is refused until the pin is updated
+	SHA256         string `toml:"sha256"`
+	TimeoutSeconds int    `toml:"timeout_seconds"` // default 5, max 60
+	// Stages: "discovery" and/or "metrics" (default: discovery)
+	Stages []string `toml:"stages"`
+	// Fields limits the RawData keys the plugin may set (empty = any)
+	Fields []string `toml:"fields"`
+}
+
+// runnerConfig converts the TOML settings for the plugins package.
+func (c PluginsConfig) runnerConfig() plugins.Config {
+	cfg := plugins.Config{WASMRuntime: c.WASMRuntime, MaxConcurrent: c.MaxConcurrent}
+	for _, e := range c.Enrichment {
+		p := plugins.Plugin{
+			Name:    e.Name,
+			Path:    e.Path,
+			Args:    e.Args,
+			SHA256:  e.SHA256,
+			Timeout: time.Duration(e.TimeoutSeconds) * time.Second,
+			Fields:  e.Fields,
+		}
+		for _, s := range e.Stages {
+			p.Stages = append(p.Stages, plugins.Stage(strings.ToLower(strings.TrimSpace(s))))
+		}
+		cfg.Plugins = append(cfg.Plugins, p)
+	}
+	return cfg
+}
+
+// DatabaseEncryptionConfig controls encryption at rest of devices.db and agent.db.
+type DatabaseEncryptionConfig struct {
+	// Enabled stores both databases as AES-256-GCM sealed images
+	// (devices.db.enc, agent.db.enc). Existing plaintext databases are
+	// migrated on the next start and then removed
+	Enabled bool `toml:"enabled"`
+	// KeyFile holds the 32-byte key (default: agent_secret.key in the data
+	// directory, the same key that protects stored device credentials)
+	KeyFile string `toml:"key_file"`
+}
+
 // WebAuthConfig controls agent UI authentication behavior
 // Mode:
 //
@@ -90,9 +237,37 @@ type WebConfig struct {
 //	"disabled" -> no auth at all (legacy behavior)
 //
 // AllowLocalAdmin: if true, loopback requests get admin principal without login
+// Users: optional local accounts that can sign in to the agent UI directly
+// KioskTokens: optional read-only tokens for embedded dashboards
 type WebAuthConfig struct {
-	Mode            string `toml:"mode"`
-	AllowLocalAdmin bool   `toml:"allow_local_admin"`
+	Mode            string          `toml:"mode"`
+	AllowLocalAdmin bool            `toml:"allow_local_admin"`
+	Users           []WebAuthUser   `toml:"users"`
+	KioskTokens     []WebKioskToken `toml:"kiosk_tokens"`
+}
+
+// WebAuthUser is a local agent UI account.
+type WebAuthUser struct {
+	Username string `toml:"username"`
+	// PasswordHash is generated with `printmaster-agent hash-password`
+	PasswordHash string `toml:"password_hash"`
+	// Role is viewer (read-only), operator (can run scans and collections)
+	// or admin (can also change settings and join a server)
+	Role string `toml:"role"`
+}
+
+// WebKioskToken is a read-only token for wall-mounted dashboards. It may only
+// read the device list, metrics and the event stream (see kiosk.go).
+type WebKioskToken struct {
+	Name string `toml:"name"`
+	// TokenHash is generated with `printmaster-agent kiosk-token`
+	TokenHash string `toml:"token_hash"`
+	// Scopes limit the token to "devices", "metrics" and/or "events"; empty
+	// allows all three
+	Scopes []string `toml:"scopes"`
+	// ExpiresAt is an RFC 3339 time or a date (valid through that day, UTC);
+	// empty never expires
+	ExpiresAt string `toml:"expires_at"`
 }
 
 // DefaultAgentConfig returns agent configuration with sensible defaults
@@ -124,6 +299,13 @@ func DefaultAgentConfig() *AgentConfig {
 			HeartbeatInterval:  60,
 			Token:              "",
 			AgentID:            "", // Will be auto-generated on first run
+			Proxy:              ServerProxyConfig{BypassLAN: true},
+			Transport:          "http",
+			MQTT: MQTTConfig{
+				TopicPrefix:      "printmaster",
+				QoS:              1,
+				KeepAliveSeconds: 60,
+			},
 		},
 		AutoUpdate: defaultAutoUpdateConfig(),
 		Database: config.DatabaseConfig{
@@ -138,6 +320,11 @@ func DefaultAgentConfig() *AgentConfig {
 			EnableTLS: false,
 			Auth:      WebAuthConfig{Mode: "local", AllowLocalAdmin: true},
 		},
+		Watchdog: WatchdogConfig{
+			Enabled:             true,
+			ChildProcess:        false,
+			StallTimeoutMinutes: 30,
+		},
 	}
 }
 
@@ -238,6 +425,37 @@ func ApplyEnvironmentOverrides(cfg *AgentConfig) {
 		lower := strings.ToLower(val)
 		cfg.Server.InsecureSkipVerify = (lower == "1" || lower == "true" || lower == "yes")
 	}
+	if val := os.Getenv("SERVER_PROXY_URL"); val != "" {
+		cfg.Server.Proxy.URL = val
+	}
+	if val := os.Getenv("SERVER_PROXY_USERNAME"); val != "" {
+		cfg.Server.Proxy.Username = val
+	}
+	if val := os.Getenv("SERVER_PROXY_PASSWORD"); val != "" {
+		cfg.Server.Proxy.Password = val
+	}
+	if val := os.Getenv("SERVER_NO_PROXY"); val != "" {
+		cfg.Server.Proxy.NoProxy = strings.Split(val, ",")
+	}
+	if val := os.Getenv("SERVER_PROXY_PAC_URL"); val != "" {
+		cfg.Server.Proxy.PACURL = val
+	}
+	if val := os.Getenv("SERVER_PROXY_BYPASS_LAN"); val != "" {
+		lower := strings.ToLower(val)
+		cfg.Server.Proxy.BypassLAN = (lower == "1" || lower == "true" || lower == "yes")
+	}
+	if val := os.Getenv("SERVER_TRANSPORT"); val != "" {
+		cfg.Server.Transport = val
+	}
+	if val := os.Getenv("MQTT_BROKER"); val != "" {
+		cfg.Server.MQTT.Broker = val
+	}
+	if val := os.Getenv("MQTT_USERNAME"); val != "" {
+		cfg.Server.MQTT.Username = val
+	}
+	if val := os.Getenv("MQTT_PASSWORD"); val != "" {
+		cfg.Server.MQTT.Password = val
+	}
 	if val := os.Getenv("WEB_HTTP_PORT"); val != "" {
 		if port, err := strconv.Atoi(val); err == nil {
 			cfg.Web.HTTPPort = port
@@ -255,6 +473,24 @@ func ApplyEnvironmentOverrides(cfg *AgentConfig) {
 		lower := strings.ToLower(val)
 		cfg.Web.Auth.AllowLocalAdmin = (lower == "1" || lower == "true" || lower == "yes")
 	}
+	if val := os.Getenv("WATCHDOG_ENABLED"); val != "" {
+		lower := strings.ToLower(val)
+		cfg.Watchdog.Enabled = (lower == "1" || lower == "true" || lower == "yes")
+	}
+	if val := os.Getenv("MDNS_ADVERTISE"); val != "" {
+		lower := strings.ToLower(val)
+		cfg.MDNS.Advertise = (lower == "1" || lower == "true" || lower == "yes")
+	}
+	if val := os.Getenv("MDNS_INSTANCE_NAME"); val != "" {
+		cfg.MDNS.InstanceName = val
+	}
+	if val := os.Getenv("AGENT_DB_ENCRYPT"); val != "" {
+		lower := strings.ToLower(val)
+		cfg.DatabaseEncryption.Enabled = (lower == "1" || lower == "true" || lower == "yes")
+	}
+	if val := os.Getenv("AGENT_DB_KEY_FILE"); val != "" {
+		cfg.DatabaseEncryption.KeyFile = val
+	}
 	if val := os.Getenv("EPSON_REMOTE_MODE_ENABLED"); val != "" {
 		lower := strings.ToLower(val)
 		cfg.EpsonRemoteModeEnabled = (lower == "1" || lower == "true" || lower == "yes")
@@ -286,7 +522,9 @@ func LoadServerToken(dataDir string) string {
 	return strings.TrimSpace(string(data))
 }
 
-// SaveServerToken saves the server authentication token to file
+// SaveServerToken saves the server authentication token to file. The token
+// is written to a temporary file and renamed into place so a crash mid-write
+// (for example during token rotation) never leaves a truncated token behind.
 func SaveServerToken(dataDir, token string) error {
 	if token == "" {
 		return nil // Don't save empty tokens
@@ -296,8 +534,29 @@ func SaveServerToken(dataDir, token string) error {
 	if err := os.MkdirAll(dataDir, 0755); err != nil {
 		return err
 	}
-	// Write with restrictive permissions (owner read/write only)
-	return os.WriteFile(tokenPath, []byte(token), 0600)
+	tmp, err := os.CreateTemp(dataDir, "agent_token.*.tmp")
+	if err != nil {
+		return err
+	}
+	tmpPath := tmp.Name()
+	defer os.Remove(tmpPath) // no-op once renamed
+	// Restrictive permissions (owner read/write only)
+	if err := tmp.Chmod(0600); err != nil {
+		tmp.Close()
+		return err
+	}
+	if _, err := tmp.Write([]byte(token)); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmpPath, tokenPath)
 }
 
 // DeleteServerToken removes the persisted server authentication token, if present.
@@ -343,6 +602,57 @@ func SaveServerJoinToken(dataDir, token string) error {
 	return os.WriteFile(tokenPath, []byte(token), 0600)
 }
 
+// LoadOrGenerateMeterReadKey loads the ed25519 key the agent signs meter
+// reads with, generating it on first use. The key lives in its own file next
+// to the agent token rather than in the database, and never changes with
+// token rotation, so reads queued offline still verify when uploaded.
+func LoadOrGenerateMeterReadKey(dataDir string) (ed25519.PrivateKey, error) {
+	keyPath := filepath.Join(dataDir, "meter_read_key")
+	if data, err := os.ReadFile(keyPath); err == nil {
+		seed, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(data)))
+		if err != nil || len(seed) != ed25519.SeedSize {
+			return nil, fmt.Errorf("invalid meter read key in %s", keyPath)
+		}
+		return ed25519.NewKeyFromSeed(seed), nil
+	} else if !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(dataDir, 0755); err != nil {
+		return nil, err
+	}
+	// Written via a temporary file so a crash never leaves a truncated key
+	tmp, err := os.CreateTemp(dataDir, "meter_read_key.*.tmp")
+	if err != nil {
+		return nil, err
+	}
+	tmpPath := tmp.Name()
+	defer os.Remove(tmpPath) // no-op once renamed
+	if err := tmp.Chmod(0600); err != nil {
+		tmp.Close()
+		return nil, err
+	}
+	if _, err := tmp.Write([]byte(base64.StdEncoding.EncodeToString(key.Seed()))); err != nil {
+		tmp.Close()
+		return nil, err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return nil, err
+	}
+	if err := tmp.Close(); err != nil {
+		return nil, err
+	}
+	if err := os.Rename(tmpPath, keyPath); err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
 // LoadOrGenerateAgentID loads the agent ID from file or generates a new UUID
 func LoadOrGenerateAgentID(dataDir string) (string, error) {
 	idPath := filepath.Join(dataDir, "agent_id")
@@ -376,3 +686,45 @@ func LoadOrGenerateAgentID(dataDir string) (string, error) {
 
 	return id, nil
 }
+
+// ConfigureDatabaseEncryption loads the database key when encryption at rest
+// is enabled, so stores opened afterwards use sealed images. The key lives in
+// the local credential key file unless KeyFile points elsewhere.
+func ConfigureDatabaseEncryption(cfg DatabaseEncryptionConfig, dataDir string) error {
+	if !cfg.Enabled {
+		return storage.SetEncryptionKey(nil)
+	}
+	keyPath := databaseEncryptionKeyPath(cfg, dataDir)
+	key, err := commonutil.LoadOrCreateKey(keyPath)
+	if err != nil {
+		return fmt.Errorf("load database encryption key %s: %w", keyPath, err)
+	}
+	return storage.SetEncryptionKey(key)
+}
+
+// readDatabaseEncryptionKey returns the configured key without creating one.
+// It is nil when encryption is off or no key file exists yet.
+func readDatabaseEncryptionKey(cfg DatabaseEncryptionConfig, dataDir string) ([]byte, error) {
+	if !cfg.Enabled {
+		return nil, nil
+	}
+	keyPath := databaseEncryptionKeyPath(cfg, dataDir)
+	key, err := os.ReadFile(keyPath)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read database encryption key %s: %w", keyPath, err)
+	}
+	if len(key) != 32 {
+		return nil, fmt.Errorf("database encryption key %s must be 32 bytes, got %d", keyPath, len(key))
+	}
+	return key, nil
+}
+
+func databaseEncryptionKeyPath(cfg DatabaseEncryptionConfig, dataDir string) string {
+	if cfg.KeyFile != "" {
+		return cfg.KeyFile
+	}
+	return filepath.Join(dataDir, "agent_secret.key")
+}
@@ -0,0 +1,190 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"printmaster/agent/agent"
+	"printmaster/agent/storage"
+)
+
+// Configuration drift
+//
+// Every discovery result that is stored is compared with the device's last
+// known configuration: sysLocation and sysContact, the network settings it
+// reports and the media loaded in each tray. A value that differs from the
+// previous reading is recorded as a drift event, which catches settings
+// changed at the panel or web UI without anyone telling the fleet owner.
+// Values a scan did not read (quick scans, SNMP timeouts) are not drift; the
+// previous value is kept until the device reports the setting again.
+
+// configDriftStore returns the drift store backing deviceStore, if any.
+func configDriftStore() storage.ConfigDriftStore {
+	store, _ := deviceStore.(storage.ConfigDriftStore)
+	return store
+}
+
+// deviceConfigValues extracts the tracked settings from a scan result.
+// Settings the device did not report are left out.
+func deviceConfigValues(pi agent.PrinterInfo) map[string]string {
+	values := make(map[string]string)
+	set := func(field, value string) {
+		if value = strings.TrimSpace(value); value != "" {
+			values[field] = value
+		}
+	}
+	set("location", pi.Location)
+	set("contact", pi.AdminContact)
+	set("hostname", pi.Hostname)
+	set("subnet_mask", pi.SubnetMask)
+	set("gateway", pi.Gateway)
+	set("dhcp_server", pi.DHCPServer)
+	if len(pi.DNSServers) > 0 {
+		dns := append([]string(nil), pi.DNSServers...)
+		sort.Strings(dns)
+		set("dns_servers", strings.Join(dns, ","))
+	}
+	for _, tray := range pi.PaperTrays {
+		if tray.Index > 0 {
+			set("tray."+strconv.Itoa(tray.Index)+".media_type", tray.MediaType)
+		}
+	}
+	return values
+}
+
+// diffDeviceConfig merges current into previous and returns the merged
+// baseline and the events for values that changed, ordered by field.
+func diffDeviceConfig(previous, current map[string]string) (map[string]string, []*storage.ConfigDriftEvent) {
+	merged := make(map[string]string, len(previous)+len(current))
+	for field, value := range previous {
+		merged[field] = value
+	}
+	var events []*storage.ConfigDriftEvent
+	for field, value := range current {
+		if before, ok := previous[field]; ok && before != value {
+			events = append(events, &storage.ConfigDriftEvent{Field: field, Before: before, After: value})
+		}
+		merged[field] = value
+	}
+	sort.Slice(events, func(i, j int) bool { return events[i].Field < events[j].Field })
+	return merged, events
+}
+
+// checkConfigDrift compares pi with the stored baseline for its device,
+// saves the updated baseline and reports any drift. The first reading of a
+// device only sets its baseline. Failures are logged and otherwise ignored.
+func checkConfigDrift(ctx context.Context, store storage.ConfigDriftStore, pi agent.PrinterInfo) []*storage.ConfigDriftEvent {
+	if store == nil || pi.Serial == "" {
+		return nil
+	}
+	current := deviceConfigValues(pi)
+	if len(current) == 0 {
+		return nil
+	}
+	baseline, err := store.GetDeviceConfigBaseline(ctx, pi.Serial)
+	if err != nil {
+		appLogger.Warn("Failed to load device config baseline", "serial", pi.Serial, "error", err)
+		return nil
+	}
+	var previous map[string]string
+	if baseline != nil {
+		previous = baseline.Values
+	}
+	merged, events := diffDeviceConfig(previous, current)
+	if baseline != nil && len(events) == 0 && len(merged) == len(previous) {
+		return nil // nothing new to store
+	}
+
+	now := time.Now()
+	for _, e := range events {
+		e.Serial = pi.Serial
+		e.IP = pi.IP
+		e.DetectedAt = now
+	}
+	next := &storage.DeviceConfigBaseline{Serial: pi.Serial, Values: merged, CapturedAt: now}
+	if err := store.SaveDeviceConfigBaseline(ctx, next, events); err != nil {
+		appLogger.Warn("Failed to save device config baseline", "serial", pi.Serial, "error", err)
+		return nil
+	}
+	reportConfigDrift(events)
+	return events
+}
+
+// reportConfigDrift logs drift events and pushes them to the UI.
+func reportConfigDrift(events []*storage.ConfigDriftEvent) {
+	for _, e := range events {
+		if appLogger != nil {
+			appLogger.Warn("Device configuration changed",
+				"serial", e.Serial, "ip", e.IP, "field", e.Field, "before", e.Before, "after", e.After)
+		}
+		if sseHub != nil {
+			sseHub.Broadcast(SSEEvent{
+				Type: "device_config_drift",
+				Data: map[string]interface{}{
+					"id":          e.ID,
+					"serial":      e.Serial,
+					"ip":          e.IP,
+					"field":       e.Field,
+					"before":      e.Before,
+					"after":       e.After,
+					"detected_at": e.DetectedAt,
+				},
+			})
+		}
+	}
+}
+
+// registerConfigDriftHandlers exposes configuration baselines and drift.
+func registerConfigDriftHandlers() {
+	// GET /api/devices/config-drift?serial=&limit= - Drift events, newest first,
+	// plus the device's current baseline when serial is given
+	http.HandleFunc("/api/devices/config-drift", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "GET only", http.StatusMethodNotAllowed)
+			return
+		}
+		store := configDriftStore()
+		if store == nil {
+			http.Error(w, "config drift tracking not supported", http.StatusNotImplemented)
+			return
+		}
+		serial := strings.TrimSpace(r.URL.Query().Get("serial"))
+		limit := 100
+		if l := r.URL.Query().Get("limit"); l != "" {
+			if parsed, err := strconv.Atoi(l); err == nil && parsed > 0 {
+				limit = parsed
+			}
+		}
+
+		ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+		defer cancel()
+		events, err := store.ListConfigDriftEvents(ctx, serial, limit)
+		if err != nil {
+			appLogger.Error("Failed to list config drift events", "serial", serial, "error", err)
+			http.Error(w, "failed to list config drift: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if events == nil {
+			events = []*storage.ConfigDriftEvent{}
+		}
+		resp := map[string]interface{}{
+			"events": events,
+			"count":  len(events),
+		}
+		if serial != "" {
+			baseline, err := store.GetDeviceConfigBaseline(ctx, serial)
+			if err != nil {
+				http.Error(w, "failed to load config baseline: "+err.Error(), http.StatusInternalServerError)
+				return
+			}
+			resp["baseline"] = baseline
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(resp)
+	})
+}
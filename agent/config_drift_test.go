@@ -0,0 +1,78 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	"printmaster/agent/agent"
+	"printmaster/agent/storage"
+)
+
+func TestDeviceConfigValues(t *testing.T) {
+	t.Parallel()
+
+	pi := agent.PrinterInfo{
+		Location:   " Room 101 ",
+		Gateway:    "10.0.0.1",
+		DNSServers: []string{"10.0.0.3", "10.0.0.2"},
+		PaperTrays: []agent.PaperTray{{Index: 1, MediaType: "A4"}, {Index: 2}},
+	}
+	got := deviceConfigValues(pi)
+	want := map[string]string{
+		"location":          "Room 101",
+		"gateway":           "10.0.0.1",
+		"dns_servers":       "10.0.0.2,10.0.0.3",
+		"tray.1.media_type": "A4",
+	}
+	if len(got) != len(want) {
+		t.Fatalf("deviceConfigValues = %v, want %v", got, want)
+	}
+	for k, v := range want {
+		if got[k] != v {
+			t.Fatalf("deviceConfigValues[%q] = %q, want %q", k, got[k], v)
+		}
+	}
+}
+
+func TestCheckConfigDrift(t *testing.T) {
+	store, err := storage.NewSQLiteStore(":memory:")
+	if err != nil {
+		t.Fatalf("failed to create store: %v", err)
+	}
+	defer store.Close()
+	ctx := context.Background()
+
+	pi := agent.PrinterInfo{Serial: "SN1", IP: "10.0.0.5", Location: "Lobby", AdminContact: "it@example.com"}
+	if events := checkConfigDrift(ctx, store, pi); len(events) != 0 {
+		t.Fatalf("first reading should only set the baseline, got %+v", events)
+	}
+
+	// A scan that did not read the contact is not drift
+	pi.AdminContact = ""
+	pi.Gateway = "10.0.0.1"
+	if events := checkConfigDrift(ctx, store, pi); len(events) != 0 {
+		t.Fatalf("missing value reported as drift: %+v", events)
+	}
+
+	pi.Location = "Warehouse"
+	pi.Gateway = "10.0.0.254"
+	events := checkConfigDrift(ctx, store, pi)
+	if len(events) != 2 || events[0].Field != "gateway" || events[1].Field != "location" {
+		t.Fatalf("events = %+v, want gateway and location", events)
+	}
+	if events[1].Before != "Lobby" || events[1].After != "Warehouse" || events[1].IP != "10.0.0.5" {
+		t.Fatalf("location event = %+v", events[1])
+	}
+
+	baseline, err := store.GetDeviceConfigBaseline(ctx, "SN1")
+	if err != nil || baseline == nil {
+		t.Fatalf("GetDeviceConfigBaseline: %+v, %v", baseline, err)
+	}
+	if baseline.Values["contact"] != "it@example.com" || baseline.Values["location"] != "Warehouse" {
+		t.Fatalf("baseline = %v", baseline.Values)
+	}
+	stored, _ := store.ListConfigDriftEvents(ctx, "SN1", 0)
+	if len(stored) != 2 {
+		t.Fatalf("stored events = %d, want 2", len(stored))
+	}
+}
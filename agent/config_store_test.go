@@ -1,11 +1,16 @@
 package main
 
-import "encoding/json"
+import (
+	"encoding/json"
+
+	"printmaster/agent/storage"
+)
 
 type fakeConfigStore struct {
-	values    map[string]interface{}
-	ranges    string
-	setCounts map[string]int
+	values       map[string]interface{}
+	ranges       string
+	setCounts    map[string]int
+	snmpProfiles []storage.SNMPProfile
 }
 
 func newFakeConfigStore() *fakeConfigStore {
@@ -47,6 +52,35 @@ func (f *fakeConfigStore) GetConfigValue(key string, dest interface{}) error {
 	return json.Unmarshal(data, dest)
 }
 
+func (f *fakeConfigStore) ListSNMPProfiles() ([]storage.SNMPProfile, error) {
+	return append([]storage.SNMPProfile(nil), f.snmpProfiles...), nil
+}
+
+func (f *fakeConfigStore) SaveSNMPProfile(p *storage.SNMPProfile) error {
+	if p.ID == 0 {
+		p.ID = int64(len(f.snmpProfiles) + 1)
+		f.snmpProfiles = append(f.snmpProfiles, *p)
+		return nil
+	}
+	for i := range f.snmpProfiles {
+		if f.snmpProfiles[i].ID == p.ID {
+			f.snmpProfiles[i] = *p
+			return nil
+		}
+	}
+	return storage.ErrNotFound
+}
+
+func (f *fakeConfigStore) DeleteSNMPProfile(id int64) error {
+	for i := range f.snmpProfiles {
+		if f.snmpProfiles[i].ID == id {
+			f.snmpProfiles = append(f.snmpProfiles[:i], f.snmpProfiles[i+1:]...)
+			return nil
+		}
+	}
+	return storage.ErrNotFound
+}
+
 func (f *fakeConfigStore) Close() error { return nil }
 
 func (f *fakeConfigStore) setCount(key string) int {
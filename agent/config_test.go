@@ -3,6 +3,7 @@ package main
 import (
 	"os"
 	"path/filepath"
+	"runtime"
 	"strings"
 	"testing"
 
@@ -563,6 +564,39 @@ func TestDeleteServerToken(t *testing.T) {
 	}
 }
 
+func TestLoadOrGenerateMeterReadKey(t *testing.T) {
+	t.Parallel()
+
+	tempDir := t.TempDir()
+	key, err := LoadOrGenerateMeterReadKey(tempDir)
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+	info, err := os.Stat(filepath.Join(tempDir, "meter_read_key"))
+	if err != nil {
+		t.Fatalf("key file not written: %v", err)
+	}
+	if runtime.GOOS != "windows" && info.Mode().Perm() != 0600 {
+		t.Errorf("expected key file mode 0600, got %v", info.Mode().Perm())
+	}
+
+	// The key must survive restarts so queued reads still verify
+	again, err := LoadOrGenerateMeterReadKey(tempDir)
+	if err != nil {
+		t.Fatalf("failed to load key: %v", err)
+	}
+	if !key.Equal(again) {
+		t.Fatal("expected the same key after reload")
+	}
+
+	if err := os.WriteFile(filepath.Join(tempDir, "meter_read_key"), []byte("garbage"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadOrGenerateMeterReadKey(tempDir); err == nil {
+		t.Fatal("expected an error for a corrupt key file rather than a silently replaced key")
+	}
+}
+
 func TestLoadServerJoinToken(t *testing.T) {
 	t.Parallel()
 
@@ -739,3 +773,64 @@ func TestServerURLAutoEnablesServer(t *testing.T) {
 		t.Error("expected server to be enabled when SERVER_ENABLED=true")
 	}
 }
+
+func TestLoadAgentConfigServerProxy(t *testing.T) {
+	configPath := filepath.Join(t.TempDir(), "config.toml")
+	configContent := `[server.proxy]
+url = "http://proxy.corp:3128"
+username = "svc"
+no_proxy = ["corp.example.com", "203.0.113.0/24"]
+`
+	if err := os.WriteFile(configPath, []byte(configContent), 0644); err != nil {
+		t.Fatalf("failed to write test config: %v", err)
+	}
+	t.Setenv("SERVER_PROXY_PASSWORD", "from-env")
+
+	cfg, err := LoadAgentConfig(configPath)
+	if err != nil {
+		t.Fatalf("failed to load config: %v", err)
+	}
+	proxy := cfg.Server.Proxy
+	if proxy.URL != "http://proxy.corp:3128" || proxy.Username != "svc" || proxy.Password != "from-env" {
+		t.Errorf("unexpected proxy config: %+v", proxy)
+	}
+	if len(proxy.NoProxy) != 2 || !proxy.BypassLAN {
+		t.Errorf("expected no_proxy entries and LAN bypass by default, got %+v", proxy)
+	}
+	if !proxy.resolverConfig().Enabled() {
+		t.Error("expected proxy to be enabled")
+	}
+}
+
+func TestLoadAgentConfigMQTTTransport(t *testing.T) {
+	configPath := filepath.Join(t.TempDir(), "config.toml")
+	configContent := `[server]
+transport = "MQTT"
+
+[server.mqtt]
+broker = "ssl://broker.corp:8883"
+username = "agent"
+`
+	if err := os.WriteFile(configPath, []byte(configContent), 0644); err != nil {
+		t.Fatalf("failed to write test config: %v", err)
+	}
+	t.Setenv("MQTT_PASSWORD", "from-env")
+
+	cfg, err := LoadAgentConfig(configPath)
+	if err != nil {
+		t.Fatalf("failed to load config: %v", err)
+	}
+	if !cfg.Server.usesMQTT() {
+		t.Fatalf("expected MQTT transport, got %q", cfg.Server.Transport)
+	}
+	mqttCfg := cfg.Server.MQTT
+	if mqttCfg.Broker != "ssl://broker.corp:8883" || mqttCfg.Password != "from-env" {
+		t.Errorf("unexpected mqtt config: %+v", mqttCfg)
+	}
+	if mqttCfg.TopicPrefix != "printmaster" || mqttCfg.QoS != 1 || mqttCfg.CleanSession {
+		t.Errorf("expected defaults for unset fields, got %+v", mqttCfg)
+	}
+	if DefaultAgentConfig().Server.usesMQTT() {
+		t.Error("HTTP should be the default transport")
+	}
+}
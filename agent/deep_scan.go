@@ -0,0 +1,290 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"printmaster/agent/agent"
+	"printmaster/agent/scanscope"
+	"printmaster/agent/storage"
+)
+
+const (
+	deepScanMaxTargets  = 1024
+	deepScanConcurrency = 4
+	deepScanJobsKept    = 10
+	deepScanMaxEntries  = 10000
+)
+
+// deepScanVolatileFields are dropped from parsed results so that scans of an
+// unchanged device compare equal.
+var deepScanVolatileFields = []string{"last_seen", "uptime_seconds", "detection_reasons", "discovery_methods"}
+
+// deepScanRequest selects the devices a deep scan walks.
+type deepScanRequest struct {
+	IPs          []string `json:"ips,omitempty"`
+	Ranges       string   `json:"ranges,omitempty"` // range text, one entry per line
+	Serials      []string `json:"serials,omitempty"`
+	IncludeSaved bool     `json:"include_saved,omitempty"`
+}
+
+// deepScanResult is the parse capture for one target.
+type deepScanResult struct {
+	IP           string                 `json:"ip"`
+	Serial       string                 `json:"serial,omitempty"`
+	Manufacturer string                 `json:"manufacturer,omitempty"`
+	Model        string                 `json:"model,omitempty"`
+	Fields       map[string]interface{} `json:"fields,omitempty"`
+	PDUCount     int                    `json:"pdu_count"`
+	DurationMS   int64                  `json:"duration_ms"`
+	Error        string                 `json:"error,omitempty"`
+}
+
+// deepScanJob tracks one asynchronous deep scan.
+type deepScanJob struct {
+	ID         string           `json:"id"`
+	Status     string           `json:"status"` // running or completed
+	Total      int              `json:"total"`
+	Done       int              `json:"done"`
+	Failed     int              `json:"failed"`
+	StartedAt  time.Time        `json:"started_at"`
+	FinishedAt *time.Time       `json:"finished_at,omitempty"`
+	Results    []deepScanResult `json:"results"`
+}
+
+// deepScanner runs full-walk deep scans in the background for the server's
+// scheduled deep-scan orchestration and keeps the most recent jobs in memory
+// until the server collects them.
+type deepScanner struct {
+	scan func(ip string) deepScanResult
+
+	mu   sync.Mutex
+	jobs []*deepScanJob
+}
+
+var deepScans = newDeepScanner()
+
+func newDeepScanner() *deepScanner {
+	return &deepScanner{scan: runDeepScan}
+}
+
+// runDeepScan performs a full diagnostic walk of ip and parses it.
+func runDeepScan(ip string) deepScanResult {
+	res := deepScanResult{IP: ip}
+	start := time.Now()
+	defer func() { res.DurationMS = time.Since(start).Milliseconds() }()
+
+	cfg, err := agent.GetSNMPConfig()
+	if err != nil {
+		res.Error = err.Error()
+		return res
+	}
+	client, err := agent.NewSNMPClient(cfg, ip, 5)
+	if err != nil {
+		res.Error = err.Error()
+		return res
+	}
+	defer client.Close()
+
+	cols := agent.FullDiagnosticWalk(client, nil, []string{"1.3.6.1.2.1", "1.3.6.1.2.1.43", "1.3.6.1.4.1"}, deepScanMaxEntries)
+	res.PDUCount = len(cols)
+	if len(cols) == 0 {
+		res.Error = "no SNMP response"
+		return res
+	}
+	pi, _ := agent.ParsePDUs(ip, cols, nil, func(string) {})
+	agent.MergeVendorMetrics(&pi, cols, "")
+	res.Serial = pi.Serial
+	res.Manufacturer = pi.Manufacturer
+	res.Model = pi.Model
+	res.Fields = deepScanFields(pi)
+	return res
+}
+
+// deepScanFields flattens parsed printer info into a field map for diffing.
+func deepScanFields(pi agent.PrinterInfo) map[string]interface{} {
+	raw, err := json.Marshal(pi)
+	if err != nil {
+		return nil
+	}
+	fields := make(map[string]interface{})
+	if err := json.Unmarshal(raw, &fields); err != nil {
+		return nil
+	}
+	for _, f := range deepScanVolatileFields {
+		delete(fields, f)
+	}
+	return fields
+}
+
+// resolveDeepScanTargets expands a request into a de-duplicated list of IPs.
+func resolveDeepScanTargets(ctx context.Context, req deepScanRequest) ([]string, error) {
+	seen := make(map[string]struct{})
+	var targets []string
+	add := func(ip string) {
+		ip = strings.TrimSpace(ip)
+		if net.ParseIP(ip) == nil || scanscope.Check(ip, "deep_scan") != nil {
+			return
+		}
+		if _, ok := seen[ip]; ok {
+			return
+		}
+		seen[ip] = struct{}{}
+		targets = append(targets, ip)
+	}
+
+	for _, ip := range req.IPs {
+		add(ip)
+	}
+	if strings.TrimSpace(req.Ranges) != "" {
+		parsed, err := agent.ParseRangeText(req.Ranges, deepScanMaxTargets)
+		if err != nil {
+			return nil, err
+		}
+		if len(parsed.Ports) > 0 {
+			rememberPortOverrides(parsed.Ports)
+		}
+		for _, ip := range parsed.IPs {
+			add(ip)
+		}
+	}
+	if deviceStore != nil {
+		for _, serial := range req.Serials {
+			if dev, err := deviceStore.Get(ctx, serial); err == nil {
+				add(dev.IP)
+			}
+		}
+		if req.IncludeSaved {
+			saved := true
+			devices, err := deviceStore.List(ctx, storage.DeviceFilter{IsSaved: &saved})
+			if err != nil {
+				return nil, err
+			}
+			for _, dev := range devices {
+				add(dev.IP)
+			}
+		}
+	}
+	if len(targets) > deepScanMaxTargets {
+		targets = targets[:deepScanMaxTargets]
+	}
+	return targets, nil
+}
+
+// Start begins scanning targets in the background and returns the job.
+func (s *deepScanner) Start(targets []string) *deepScanJob {
+	id := make([]byte, 8)
+	_, _ = rand.Read(id)
+	job := &deepScanJob{
+		ID:        hex.EncodeToString(id),
+		Status:    "running",
+		Total:     len(targets),
+		StartedAt: time.Now().UTC(),
+		Results:   []deepScanResult{},
+	}
+	s.mu.Lock()
+	s.jobs = append([]*deepScanJob{job}, s.jobs...)
+	if len(s.jobs) > deepScanJobsKept {
+		s.jobs = s.jobs[:deepScanJobsKept]
+	}
+	s.mu.Unlock()
+
+	go s.run(job, targets)
+	return job
+}
+
+func (s *deepScanner) run(job *deepScanJob, targets []string) {
+	work := make(chan string)
+	var wg sync.WaitGroup
+	for i := 0; i < deepScanConcurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for ip := range work {
+				res := s.scan(ip)
+				s.mu.Lock()
+				job.Results = append(job.Results, res)
+				job.Done++
+				if res.Error != "" {
+					job.Failed++
+				}
+				s.mu.Unlock()
+			}
+		}()
+	}
+	for _, ip := range targets {
+		work <- ip
+	}
+	close(work)
+	wg.Wait()
+
+	finished := time.Now().UTC()
+	s.mu.Lock()
+	job.Status = "completed"
+	job.FinishedAt = &finished
+	s.mu.Unlock()
+	if appLogger != nil {
+		appLogger.Info("Deep scan finished", "job", job.ID, "total", job.Total, "failed", job.Failed)
+	}
+}
+
+// Get returns a snapshot of the job with the given id.
+func (s *deepScanner) Get(id string) (deepScanJob, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, job := range s.jobs {
+		if job.ID == id {
+			snapshot := *job
+			snapshot.Results = append([]deepScanResult(nil), job.Results...)
+			return snapshot, true
+		}
+	}
+	return deepScanJob{}, false
+}
+
+// registerDeepScanHandlers exposes deep scan jobs for server orchestration.
+func registerDeepScanHandlers() {
+	// POST /api/deep-scan - start a deep scan job
+	// GET  /api/deep-scan?id=<job> - job progress and results
+	http.HandleFunc("/api/deep-scan", func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodPost:
+			var req deepScanRequest
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+				http.Error(w, "bad json", http.StatusBadRequest)
+				return
+			}
+			ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+			defer cancel()
+			targets, err := resolveDeepScanTargets(ctx, req)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			job := deepScans.Start(targets)
+			if appLogger != nil {
+				appLogger.Info("Deep scan started", "job", job.ID, "targets", len(targets))
+			}
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusAccepted)
+			json.NewEncoder(w).Encode(map[string]interface{}{"job_id": job.ID, "total": len(targets)})
+		case http.MethodGet:
+			job, ok := deepScans.Get(r.URL.Query().Get("id"))
+			if !ok {
+				http.Error(w, "job not found", http.StatusNotFound)
+				return
+			}
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(job)
+		default:
+			http.Error(w, "GET or POST only", http.StatusMethodNotAllowed)
+		}
+	})
+}
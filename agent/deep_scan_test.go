@@ -0,0 +1,70 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"printmaster/agent/agent"
+)
+
+func TestDeepScannerRunsJob(t *testing.T) {
+	t.Parallel()
+	s := newDeepScanner()
+	s.scan = func(ip string) deepScanResult {
+		if ip == "10.0.0.3" {
+			return deepScanResult{IP: ip, Error: "no SNMP response"}
+		}
+		return deepScanResult{IP: ip, Serial: "SN-" + ip, PDUCount: 10}
+	}
+
+	job := s.Start([]string{"10.0.0.1", "10.0.0.2", "10.0.0.3"})
+	deadline := time.Now().Add(5 * time.Second)
+	var got deepScanJob
+	for time.Now().Before(deadline) {
+		got, _ = s.Get(job.ID)
+		if got.Status == "completed" {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if got.Status != "completed" || got.Done != 3 || got.Failed != 1 || len(got.Results) != 3 {
+		t.Fatalf("unexpected job state: %+v", got)
+	}
+	if _, ok := s.Get("missing"); ok {
+		t.Fatal("expected unknown job to be missing")
+	}
+}
+
+func TestResolveDeepScanTargets(t *testing.T) {
+	t.Parallel()
+	targets, err := resolveDeepScanTargets(context.Background(), deepScanRequest{
+		IPs:    []string{"10.0.0.5", "not-an-ip", "10.0.0.5"},
+		Ranges: "10.0.0.4-6",
+	})
+	if err != nil {
+		t.Fatalf("resolveDeepScanTargets: %v", err)
+	}
+	want := []string{"10.0.0.5", "10.0.0.4", "10.0.0.6"}
+	if len(targets) != len(want) {
+		t.Fatalf("targets = %v, want %v", targets, want)
+	}
+	for i := range want {
+		if targets[i] != want[i] {
+			t.Fatalf("targets = %v, want %v", targets, want)
+		}
+	}
+}
+
+func TestDeepScanFieldsDropsVolatile(t *testing.T) {
+	t.Parallel()
+	fields := deepScanFields(agent.PrinterInfo{IP: "10.0.0.1", Serial: "ABC", PageCount: 42, UptimeSeconds: 99, LastSeen: time.Now()})
+	if fields["serial"] != "ABC" || fields["page_count"] != float64(42) {
+		t.Fatalf("unexpected fields: %v", fields)
+	}
+	for _, f := range deepScanVolatileFields {
+		if _, ok := fields[f]; ok {
+			t.Errorf("expected %s to be dropped", f)
+		}
+	}
+}
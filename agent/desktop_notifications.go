@@ -0,0 +1,174 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"printmaster/agent/agent"
+	"printmaster/agent/desktopnotify"
+	pmsettings "printmaster/common/settings"
+)
+
+// criticalStatusPattern matches device status messages that stop printing or
+// need someone on site. Warnings such as "toner low" are deliberately left out
+// so field technicians are only interrupted for real problems.
+var criticalStatusPattern = regexp.MustCompile(`(?i)\b(jam(med)?|(door|cover|lid|tray)( is)? open|open (door|cover)|out of paper|(paper|toner) (out|empty)|service (call|required|needed)|call service|fault|failure|fatal|malfunction|offline|error)\b`)
+
+// nonCriticalStatusPattern excludes messages that merely mention an error word.
+var nonCriticalStatusPattern = regexp.MustCompile(`(?i)\b(no (error|errors|jam)|error[- ]free|0 errors?|cleared)\b`)
+
+// desktopNotifier shows native OS notifications for scan completion and
+// critical device errors when the agent runs in an interactive session.
+type desktopNotifier struct {
+	mu          sync.Mutex
+	interactive bool
+	prefs       pmsettings.NotificationSettings
+	active      map[string]map[string]struct{} // Device serial -> critical messages already notified
+	show        func(ctx context.Context, title, message string) error
+	supported   func() bool
+	warned      bool
+}
+
+var desktopNotifications = &desktopNotifier{
+	prefs:     pmsettings.DefaultSettings().Notifications,
+	show:      desktopnotify.Show,
+	supported: desktopnotify.Supported,
+}
+
+// setInteractive records whether the agent runs in a user session. Services
+// never show notifications.
+func (n *desktopNotifier) setInteractive(interactive bool) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.interactive = interactive
+}
+
+// setPreferences applies the agent-local notification settings.
+func (n *desktopNotifier) setPreferences(prefs pmsettings.NotificationSettings) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.prefs = prefs
+}
+
+// available reports whether notifications can be shown at all, regardless of
+// the user's preferences.
+func (n *desktopNotifier) available() bool {
+	n.mu.Lock()
+	interactive := n.interactive
+	n.mu.Unlock()
+	return interactive && n.supported()
+}
+
+func (n *desktopNotifier) enabled(kind func(pmsettings.NotificationSettings) bool) bool {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return n.interactive && n.prefs.DesktopEnabled && kind(n.prefs)
+}
+
+// scanCompleted notifies that a manually started discovery scan finished.
+func (n *desktopNotifier) scanCompleted(found int, elapsed time.Duration, err error) {
+	if !n.enabled(func(p pmsettings.NotificationSettings) bool { return p.ScanComplete }) {
+		return
+	}
+	if err != nil {
+		n.send("Discovery scan failed", err.Error())
+		return
+	}
+	noun := "printers"
+	if found == 1 {
+		noun = "printer"
+	}
+	n.send("Discovery scan complete", fmt.Sprintf("Found %d %s in %s.", found, noun, elapsed.Round(time.Second)))
+}
+
+// deviceStatus notifies about critical errors a device reports. Each message
+// is announced once; it is announced again only after the device has
+// reported without it in between.
+func (n *desktopNotifier) deviceStatus(pi agent.PrinterInfo) {
+	key := pi.Serial
+	if key == "" {
+		key = pi.IP
+	}
+	if key == "" {
+		return
+	}
+	critical := criticalStatusMessages(pi.StatusMessages)
+
+	n.mu.Lock()
+	if n.active == nil {
+		n.active = make(map[string]map[string]struct{})
+	}
+	previous := n.active[key]
+	current := make(map[string]struct{}, len(critical))
+	var fresh []string
+	for _, msg := range critical {
+		current[msg] = struct{}{}
+		if _, seen := previous[msg]; !seen {
+			fresh = append(fresh, msg)
+		}
+	}
+	if len(current) == 0 {
+		delete(n.active, key)
+	} else {
+		n.active[key] = current
+	}
+	n.mu.Unlock()
+
+	if len(fresh) == 0 || !n.enabled(func(p pmsettings.NotificationSettings) bool { return p.DeviceErrors }) {
+		return
+	}
+	name := strings.TrimSpace(pi.Manufacturer + " " + pi.Model)
+	if name == "" {
+		name = "Printer"
+	}
+	title := fmt.Sprintf("%s at %s needs attention", name, pi.IP)
+	n.send(title, strings.Join(fresh, "; "))
+}
+
+// send shows a notification in the background so scans are never blocked by
+// the OS notification helper.
+func (n *desktopNotifier) send(title, message string) {
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+		defer cancel()
+		if err := n.show(ctx, title, message); err != nil {
+			n.mu.Lock()
+			first := !n.warned
+			n.warned = true
+			n.mu.Unlock()
+			if appLogger == nil {
+				return
+			}
+			if first {
+				appLogger.Warn("Desktop notification failed", "error", err)
+			} else {
+				appLogger.Debug("Desktop notification failed", "error", err)
+			}
+		}
+	}()
+}
+
+// criticalStatusMessages returns the distinct status messages that describe a
+// critical condition, sorted for stable output.
+func criticalStatusMessages(messages []string) []string {
+	seen := make(map[string]struct{})
+	var out []string
+	for _, msg := range messages {
+		msg = strings.Join(strings.Fields(msg), " ")
+		if msg == "" || nonCriticalStatusPattern.MatchString(msg) || !criticalStatusPattern.MatchString(msg) {
+			continue
+		}
+		if _, dup := seen[msg]; dup {
+			continue
+		}
+		seen[msg] = struct{}{}
+		out = append(out, msg)
+	}
+	sort.Strings(out)
+	return out
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"printmaster/agent/agent"
+	pmsettings "printmaster/common/settings"
+)
+
+func TestCriticalStatusMessages(t *testing.T) {
+	t.Parallel()
+	got := criticalStatusMessages([]string{
+		"Paper jam in tray 2",
+		"Toner low",
+		"Front  door open",
+		"Ready",
+		"No errors",
+		"Paper jam in tray 2",
+		"Service call 49.4C02",
+	})
+	want := []string{"Front door open", "Paper jam in tray 2", "Service call 49.4C02"}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("criticalStatusMessages = %v, want %v", got, want)
+	}
+}
+
+func newTestNotifier(interactive bool) (*desktopNotifier, chan string) {
+	shown := make(chan string, 10)
+	n := &desktopNotifier{
+		interactive: interactive,
+		prefs:       pmsettings.DefaultSettings().Notifications,
+		show: func(ctx context.Context, title, message string) error {
+			shown <- title + ": " + message
+			return nil
+		},
+		supported: func() bool { return true },
+	}
+	return n, shown
+}
+
+func expectNotifications(t *testing.T, shown chan string, count int) []string {
+	t.Helper()
+	var got []string
+	timeout := time.After(time.Second)
+	for len(got) < count {
+		select {
+		case msg := <-shown:
+			got = append(got, msg)
+		case <-timeout:
+			t.Fatalf("expected %d notifications, got %v", count, got)
+		}
+	}
+	select {
+	case msg := <-shown:
+		t.Fatalf("unexpected extra notification %q", msg)
+	case <-time.After(50 * time.Millisecond):
+	}
+	return got
+}
+
+func TestDesktopNotifierDeviceStatusDedupes(t *testing.T) {
+	t.Parallel()
+	n, shown := newTestNotifier(true)
+	pi := agent.PrinterInfo{IP: "10.0.0.5", Serial: "SN1", Manufacturer: "HP", Model: "M404", StatusMessages: []string{"Paper jam"}}
+
+	n.deviceStatus(pi)
+	n.deviceStatus(pi) // Same error again: no repeat
+	got := expectNotifications(t, shown, 1)
+	if got[0] != "HP M404 at 10.0.0.5 needs attention: Paper jam" {
+		t.Fatalf("unexpected notification %q", got[0])
+	}
+
+	// Clearing the error re-arms it
+	pi.StatusMessages = nil
+	n.deviceStatus(pi)
+	pi.StatusMessages = []string{"Paper jam"}
+	n.deviceStatus(pi)
+	expectNotifications(t, shown, 1)
+}
+
+func TestDesktopNotifierRespectsPreferences(t *testing.T) {
+	t.Parallel()
+	service, shown := newTestNotifier(false)
+	service.scanCompleted(3, 2*time.Second, nil)
+	expectNotifications(t, shown, 0)
+	if service.available() {
+		t.Fatal("service session should not report notifications as available")
+	}
+
+	n, shown := newTestNotifier(true)
+	n.setPreferences(pmsettings.NotificationSettings{DesktopEnabled: true, ScanComplete: false, DeviceErrors: true})
+	n.scanCompleted(3, 2*time.Second, nil)
+	expectNotifications(t, shown, 0)
+
+	n.setPreferences(pmsettings.NotificationSettings{DesktopEnabled: true, ScanComplete: true, DeviceErrors: true})
+	n.scanCompleted(1, 2*time.Second, nil)
+	got := expectNotifications(t, shown, 1)
+	if got[0] != "Discovery scan complete: Found 1 printer in 2s." {
+		t.Fatalf("unexpected notification %q", got[0])
+	}
+}
@@ -0,0 +1,38 @@
+// Package desktopnotify shows native desktop notifications: Windows toasts,
+// macOS Notification Center banners and freedesktop notifications on Linux.
+// It is only meaningful for an agent running in a user's session; services
+// have no desktop to show them on.
+package desktopnotify
+
+import (
+	"context"
+	"errors"
+	"strings"
+)
+
+// AppName is the application name shown with notifications.
+const AppName = "PrintMaster Agent"
+
+// ErrUnsupported is returned when the platform has no notification mechanism
+// the agent can use.
+var ErrUnsupported = errors.New("desktop notifications are not supported on this platform")
+
+// maxMessageLength keeps notifications readable; OS banners truncate anyway.
+const maxMessageLength = 240
+
+// Show displays a notification with the given title and message.
+func Show(ctx context.Context, title, message string) error {
+	if !Supported() {
+		return ErrUnsupported
+	}
+	return show(ctx, clean(title), clean(message))
+}
+
+// clean flattens and shortens text for a single notification banner.
+func clean(s string) string {
+	s = strings.Join(strings.Fields(s), " ")
+	if r := []rune(s); len(r) > maxMessageLength {
+		s = string(r[:maxMessageLength-1]) + "…"
+	}
+	return s
+}
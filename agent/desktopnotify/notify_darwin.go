@@ -0,0 +1,31 @@
+//go:build darwin
+// +build darwin
+
+package desktopnotify
+
+import (
+	"context"
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// notifyScript receives title and message as arguments so they are never
+// interpreted as AppleScript.
+const notifyScript = `on run argv
+	display notification (item 2 of argv) with title (item 1 of argv) subtitle (item 3 of argv)
+end run`
+
+// Supported reports whether Notification Center can be reached via osascript.
+func Supported() bool {
+	_, err := exec.LookPath("osascript")
+	return err == nil
+}
+
+func show(ctx context.Context, title, message string) error {
+	cmd := exec.CommandContext(ctx, "osascript", "-e", notifyScript, title, message, AppName)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("notification failed: %w: %s", err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
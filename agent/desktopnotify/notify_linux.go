@@ -0,0 +1,29 @@
+//go:build linux
+// +build linux
+
+package desktopnotify
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// Supported reports whether a graphical session and notify-send are available.
+func Supported() bool {
+	if os.Getenv("DISPLAY") == "" && os.Getenv("WAYLAND_DISPLAY") == "" {
+		return false
+	}
+	_, err := exec.LookPath("notify-send")
+	return err == nil
+}
+
+func show(ctx context.Context, title, message string) error {
+	cmd := exec.CommandContext(ctx, "notify-send", "--app-name="+AppName, "--", title, message)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("notification failed: %w: %s", err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
@@ -0,0 +1,15 @@
+//go:build !windows && !darwin && !linux
+// +build !windows,!darwin,!linux
+
+package desktopnotify
+
+import "context"
+
+// Supported reports false: there is no notification mechanism on this platform.
+func Supported() bool {
+	return false
+}
+
+func show(ctx context.Context, title, message string) error {
+	return ErrUnsupported
+}
@@ -0,0 +1,18 @@
+package desktopnotify
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestClean(t *testing.T) {
+	t.Parallel()
+	if got := clean("  Paper jam\n\tin tray 2  "); got != "Paper jam in tray 2" {
+		t.Errorf("clean() = %q", got)
+	}
+	long := clean(strings.Repeat("é", 500))
+	if n := utf8.RuneCountInString(long); n != maxMessageLength || !strings.HasSuffix(long, "…") {
+		t.Errorf("long message not truncated: %d runes", n)
+	}
+}
@@ -0,0 +1,54 @@
+//go:build windows
+// +build windows
+
+package desktopnotify
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"syscall"
+)
+
+// powershellAppID is the AppUserModelID of Windows PowerShell, which is
+// registered on every install and can therefore post toasts without the agent
+// registering its own Start menu shortcut.
+const powershellAppID = `{1AC14E77-02E7-4E5D-B744-2EB1AE5198B7}\WindowsPowerShell\v1.0\powershell.exe`
+
+// toastScript builds the toast from environment variables so title and
+// message never have to be quoted into the script itself.
+const toastScript = `
+[Windows.UI.Notifications.ToastNotificationManager, Windows.UI.Notifications, ContentType = WindowsRuntime] | Out-Null
+[Windows.Data.Xml.Dom.XmlDocument, Windows.Data.Xml.Dom.XmlDocument, ContentType = WindowsRuntime] | Out-Null
+$title = [Security.SecurityElement]::Escape($env:PM_TOAST_TITLE)
+$body = [Security.SecurityElement]::Escape($env:PM_TOAST_BODY)
+$app = [Security.SecurityElement]::Escape($env:PM_TOAST_APP)
+$xml = New-Object Windows.Data.Xml.Dom.XmlDocument
+$xml.LoadXml("<toast><visual><binding template=""ToastGeneric""><text>$title</text><text>$body</text><text placement=""attribution"">$app</text></binding></visual></toast>")
+$toast = New-Object Windows.UI.Notifications.ToastNotification $xml
+[Windows.UI.Notifications.ToastNotificationManager]::CreateToastNotifier($env:PM_TOAST_APPID).Show($toast)
+`
+
+// Supported reports whether toasts can be shown (Windows 10 and later ship
+// the WinRT notification API used here).
+func Supported() bool {
+	_, err := exec.LookPath("powershell.exe")
+	return err == nil
+}
+
+func show(ctx context.Context, title, message string) error {
+	cmd := exec.CommandContext(ctx, "powershell.exe", "-NoProfile", "-NonInteractive", "-ExecutionPolicy", "Bypass", "-Command", toastScript)
+	cmd.Env = append(os.Environ(),
+		"PM_TOAST_TITLE="+title,
+		"PM_TOAST_BODY="+message,
+		"PM_TOAST_APP="+AppName,
+		"PM_TOAST_APPID="+powershellAppID,
+	)
+	cmd.SysProcAttr = &syscall.SysProcAttr{HideWindow: true}
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("toast notification failed: %w: %s", err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
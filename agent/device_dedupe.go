@@ -0,0 +1,160 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"printmaster/agent/storage"
+	commonstorage "printmaster/common/storage"
+)
+
+// Duplicate device reconciliation. Older parser versions stored devices
+// whose serial could not be read under a stand-in key (the IP or MAC
+// address) or with a garbled serial (padding, control characters). Once
+// the parser reads the real serial, the same printer is stored again and
+// both records show up. The reconciler finds such records and merges them
+// into the record with the proper serial.
+
+// deviceReconcileInterval is how often the reconciler runs in the background.
+const deviceReconcileInterval = 6 * time.Hour
+
+// deviceMerge describes one duplicate and the record it belongs to.
+type deviceMerge struct {
+	From   string `json:"from"`
+	To     string `json:"to"`
+	Reason string `json:"reason"` // normalized_serial, mac, ip
+}
+
+// reconcilable reports whether a device takes part in reconciliation. Spooler
+// and USB devices use their own naming and are left alone.
+func reconcilable(d *storage.Device) bool {
+	return !d.IsUSB && d.DeviceType != "usb" && d.SourceType != commonstorage.SourceTypeSpooler
+}
+
+// findDuplicateDevices pairs each pseudo-serial record with a canonical
+// record. A match is taken, in order, from the cleaned serial, a shared MAC
+// address, or a shared IP with a compatible make and model; MAC and IP
+// matches must be unambiguous.
+func findDuplicateDevices(devices []*storage.Device) []deviceMerge {
+	var canonical, pseudo []*storage.Device
+	bySerial := make(map[string]*storage.Device)
+	for _, d := range devices {
+		if d == nil || !reconcilable(d) {
+			continue
+		}
+		if commonstorage.IsPseudoSerial(d.Serial) {
+			pseudo = append(pseudo, d)
+			continue
+		}
+		canonical = append(canonical, d)
+		bySerial[strings.ToUpper(d.Serial)] = d
+	}
+
+	var merges []deviceMerge
+	for _, p := range pseudo {
+		if c := bySerial[strings.ToUpper(commonstorage.CleanSerial(p.Serial))]; c != nil {
+			merges = append(merges, deviceMerge{From: p.Serial, To: c.Serial, Reason: "normalized_serial"})
+			continue
+		}
+		if mac := normalizeMAC(p.MACAddress); mac != "" {
+			if c := uniqueMatch(canonical, func(c *storage.Device) bool { return normalizeMAC(c.MACAddress) == mac }); c != nil {
+				merges = append(merges, deviceMerge{From: p.Serial, To: c.Serial, Reason: "mac"})
+				continue
+			}
+		}
+		if p.IP != "" {
+			c := uniqueMatch(canonical, func(c *storage.Device) bool {
+				return c.IP == p.IP && compatibleField(c.Manufacturer, p.Manufacturer) && compatibleField(c.Model, p.Model)
+			})
+			if c != nil {
+				merges = append(merges, deviceMerge{From: p.Serial, To: c.Serial, Reason: "ip"})
+			}
+		}
+	}
+	return merges
+}
+
+// uniqueMatch returns the only device matching fn, or nil if none or several do.
+func uniqueMatch(devices []*storage.Device, fn func(*storage.Device) bool) *storage.Device {
+	var found *storage.Device
+	for _, d := range devices {
+		if !fn(d) {
+			continue
+		}
+		if found != nil {
+			return nil
+		}
+		found = d
+	}
+	return found
+}
+
+func compatibleField(a, b string) bool {
+	a, b = strings.TrimSpace(a), strings.TrimSpace(b)
+	return a == "" || b == "" || strings.EqualFold(a, b)
+}
+
+// reconcileDuplicateDevices finds and, unless dryRun is set, merges
+// duplicate device records. It returns the merges found (dry run) or
+// applied.
+func reconcileDuplicateDevices(ctx context.Context, store storage.DeviceStore, dryRun bool) ([]deviceMerge, error) {
+	merger, ok := store.(storage.DeviceMerger)
+	if !ok {
+		return nil, nil
+	}
+	devices, err := store.List(ctx, storage.DeviceFilter{})
+	if err != nil {
+		return nil, err
+	}
+	merges := findDuplicateDevices(devices)
+	if dryRun {
+		return merges, nil
+	}
+
+	applied := make([]deviceMerge, 0, len(merges))
+	for _, m := range merges {
+		if err := merger.MergeDevice(ctx, m.From, m.To); err != nil {
+			if appLogger != nil {
+				appLogger.Warn("Device reconciliation: merge failed", "from", m.From, "to", m.To, "error", err)
+			}
+			continue
+		}
+		applied = append(applied, m)
+		if appLogger != nil {
+			appLogger.Info("Merged duplicate device", "from", m.From, "to", m.To, "reason", m.Reason)
+		}
+		notifyServerDeviceDeleted(m.From)
+	}
+	if len(applied) > 0 && sseHub != nil {
+		sseHub.Broadcast(SSEEvent{
+			Type: "devices_merged",
+			Data: map[string]interface{}{"merges": applied},
+		})
+	}
+	return applied, nil
+}
+
+// runDeviceReconciler merges duplicate records shortly after startup and
+// then periodically, so records left by older parser versions are folded
+// in once the real serial has been discovered.
+func runDeviceReconciler(ctx context.Context, store storage.DeviceStore) {
+	ticker := time.NewTicker(deviceReconcileInterval)
+	defer ticker.Stop()
+
+	select {
+	case <-time.After(time.Minute):
+	case <-ctx.Done():
+		return
+	}
+	for {
+		if _, err := reconcileDuplicateDevices(ctx, store, false); err != nil && appLogger != nil {
+			appLogger.Error("Device reconciliation failed", "error", err)
+		}
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"printmaster/agent/storage"
+)
+
+func newDedupeDevice(serial, ip, mac, manufacturer, model string) *storage.Device {
+	d := &storage.Device{Visible: true}
+	d.Serial, d.IP, d.MACAddress, d.Manufacturer, d.Model = serial, ip, mac, manufacturer, model
+	return d
+}
+
+func TestFindDuplicateDevices(t *testing.T) {
+	t.Parallel()
+
+	devices := []*storage.Device{
+		newDedupeDevice("CNB1234567", "10.0.0.5", "00:11:22:33:44:55", "HP", "M404"),
+		newDedupeDevice("X3E012345", "10.0.0.6", "", "Brother", "HL-L2350"),
+		newDedupeDevice("Z1", "10.0.0.7", "", "Canon", ""),
+		newDedupeDevice("Z2", "10.0.0.7", "", "Canon", ""),
+		newDedupeDevice("cnb1234567\x00", "10.0.0.5", "", "", ""),                 // garbled serial
+		newDedupeDevice("00-11-22-33-44-55", "10.0.0.99", "001122334455", "", ""), // MAC-keyed
+		newDedupeDevice("10.0.0.6", "10.0.0.6", "", "Brother", ""),                // IP-keyed
+		newDedupeDevice("10.0.0.7", "10.0.0.7", "", "Canon", ""),                  // ambiguous
+		newDedupeDevice("10.0.0.8", "10.0.0.6", "", "Kyocera", ""),                // wrong make at that IP
+	}
+	usb := newDedupeDevice("10.0.0.5", "10.0.0.5", "", "", "")
+	usb.IsUSB = true
+	devices = append(devices, usb)
+
+	got := findDuplicateDevices(devices)
+	want := []deviceMerge{
+		{From: "cnb1234567\x00", To: "CNB1234567", Reason: "normalized_serial"},
+		{From: "00-11-22-33-44-55", To: "CNB1234567", Reason: "mac"},
+		{From: "10.0.0.6", To: "X3E012345", Reason: "ip"},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("expected %d merges, got %+v", len(want), got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("merge %d: expected %+v, got %+v", i, want[i], got[i])
+		}
+	}
+}
+
+func TestReconcileDuplicateDevices(t *testing.T) {
+	store, err := storage.NewSQLiteStore(":memory:")
+	if err != nil {
+		t.Fatalf("NewSQLiteStore: %v", err)
+	}
+	defer store.Close()
+	ctx := context.Background()
+	for _, d := range []*storage.Device{
+		newDedupeDevice("CNB1234567", "10.0.0.5", "", "HP", "M404"),
+		newDedupeDevice("10.0.0.5", "10.0.0.5", "", "HP", ""),
+	} {
+		if err := store.Create(ctx, d); err != nil {
+			t.Fatalf("Create: %v", err)
+		}
+	}
+
+	merges, err := reconcileDuplicateDevices(ctx, store, true)
+	if err != nil || len(merges) != 1 {
+		t.Fatalf("dry run: %+v %v", merges, err)
+	}
+	if _, err := store.Get(ctx, "10.0.0.5"); err != nil {
+		t.Fatalf("dry run should not merge: %v", err)
+	}
+
+	if merges, err = reconcileDuplicateDevices(ctx, store, false); err != nil || len(merges) != 1 {
+		t.Fatalf("reconcile: %+v %v", merges, err)
+	}
+	if _, err := store.Get(ctx, "10.0.0.5"); !errors.Is(err, storage.ErrNotFound) {
+		t.Fatalf("expected duplicate removed, got %v", err)
+	}
+}
@@ -0,0 +1,172 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"printmaster/agent/agent"
+	"printmaster/agent/scanner"
+	"printmaster/agent/storage"
+)
+
+// relocateCooldown limits how often a single device is searched for, so a
+// device that is simply switched off doesn't cost a probe on every request.
+const relocateCooldown = 2 * time.Minute
+
+// deviceRelocator finds devices whose IP changed since the last scan (new
+// DHCP lease, moved to another port). Candidate addresses come from the ARP
+// cache (by MAC) and the device's hostname; a candidate is only accepted
+// after its serial number is confirmed over SNMP.
+type deviceRelocator struct {
+	probeSerial func(ctx context.Context, ip string) (string, error)
+	arpTable    func() ([]agent.ARPEntry, error)
+	lookupHost  func(ctx context.Context, host string) ([]string, error)
+	cooldown    time.Duration
+
+	mu       sync.Mutex
+	attempts map[string]time.Time
+}
+
+var relocator = newDeviceRelocator()
+
+func newDeviceRelocator() *deviceRelocator {
+	return &deviceRelocator{
+		probeSerial: probeDeviceSerial,
+		arpTable:    agent.GetARPTable,
+		lookupHost:  net.DefaultResolver.LookupHost,
+		cooldown:    relocateCooldown,
+		attempts:    make(map[string]time.Time),
+	}
+}
+
+// probeDeviceSerial reads the serial number at ip with the minimal SNMP query.
+func probeDeviceSerial(ctx context.Context, ip string) (string, error) {
+	result, err := scanner.QueryDevice(ctx, ip, scanner.QueryMinimal, "", 3)
+	if err != nil {
+		return "", err
+	}
+	if result == nil || len(result.PDUs) == 0 {
+		return "", fmt.Errorf("no SNMP data received from %s", ip)
+	}
+	pi, _ := agent.ParsePDUs(ip, result.PDUs, &agent.ScanMeta{}, func(string) {})
+	return strings.TrimSpace(pi.Serial), nil
+}
+
+// Relocate is called after a device could not be reached at device.IP. It
+// confirms the device is not answering there and searches the candidate
+// addresses. When the device is found, device.IP (and a WebUIURL pointing at
+// the old address) is corrected and saved, and true is returned so the
+// caller can retry.
+func (r *deviceRelocator) Relocate(ctx context.Context, store storage.DeviceStore, device *storage.Device) bool {
+	if device == nil || device.Serial == "" || device.IP == "" || device.IsUSB || device.DeviceType == "usb" {
+		return false
+	}
+	if deviceFieldLocked(device, "ip") || ipConflicts.suspended(device.Serial) {
+		return false
+	}
+
+	r.mu.Lock()
+	if last, ok := r.attempts[device.Serial]; ok && time.Since(last) < r.cooldown {
+		r.mu.Unlock()
+		return false
+	}
+	r.attempts[device.Serial] = time.Now()
+	r.mu.Unlock()
+
+	// Still answering with the right serial: the failure is something else
+	if serial, err := r.probeSerial(ctx, device.IP); err == nil && strings.EqualFold(serial, device.Serial) {
+		return false
+	}
+
+	candidates := r.candidates(ctx, device)
+	for _, ip := range candidates {
+		serial, err := r.probeSerial(ctx, ip)
+		if err != nil || !strings.EqualFold(serial, device.Serial) {
+			continue
+		}
+		oldIP := device.IP
+		device.IP = ip
+		device.WebUIURL = replaceURLHost(device.WebUIURL, oldIP, ip)
+		if store != nil {
+			if err := store.Update(ctx, device); err != nil && appLogger != nil {
+				appLogger.Warn("Device relocation: failed to save new IP", "serial", device.Serial, "ip", ip, "error", err)
+			}
+		}
+		if appLogger != nil {
+			appLogger.Info("Device relocated", "serial", device.Serial, "old_ip", oldIP, "new_ip", ip)
+		}
+		return true
+	}
+
+	if appLogger != nil {
+		appLogger.WarnRateLimited("relocate_"+device.Serial, 10*time.Minute, "Device relocation: device not found",
+			"serial", device.Serial, "ip", device.IP, "candidates", len(candidates))
+	}
+	return false
+}
+
+// candidates returns addresses the device may have moved to, excluding its
+// current IP: ARP entries with its MAC, then its hostname's addresses.
+func (r *deviceRelocator) candidates(ctx context.Context, device *storage.Device) []string {
+	var out []string
+	seen := map[string]bool{device.IP: true}
+	add := func(ip string) {
+		if net.ParseIP(ip) != nil && !seen[ip] {
+			seen[ip] = true
+			out = append(out, ip)
+		}
+	}
+
+	if mac := normalizeMAC(device.MACAddress); mac != "" && r.arpTable != nil {
+		if entries, err := r.arpTable(); err == nil {
+			for _, e := range entries {
+				if normalizeMAC(e.MAC) == mac {
+					add(e.IP)
+				}
+			}
+		}
+	}
+
+	host := strings.TrimSpace(device.Hostname)
+	if host != "" && net.ParseIP(host) == nil && r.lookupHost != nil {
+		lookupCtx, cancel := context.WithTimeout(ctx, 2*time.Second)
+		addrs, err := r.lookupHost(lookupCtx, host)
+		cancel()
+		if err == nil {
+			for _, a := range addrs {
+				add(a)
+			}
+		}
+	}
+	return out
+}
+
+// normalizeMAC lower-cases a MAC address and strips separators so the ARP
+// formats of different platforms compare equal.
+func normalizeMAC(mac string) string {
+	mac = strings.ToLower(strings.TrimSpace(mac))
+	return strings.NewReplacer(":", "", "-", "", ".", "").Replace(mac)
+}
+
+// replaceURLHost swaps oldIP for newIP in rawURL's host, keeping the port.
+// URLs that don't point at oldIP are returned unchanged.
+func replaceURLHost(rawURL, oldIP, newIP string) string {
+	if rawURL == "" {
+		return rawURL
+	}
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Hostname() != oldIP {
+		return rawURL
+	}
+	if port := u.Port(); port != "" {
+		u.Host = net.JoinHostPort(newIP, port)
+	} else {
+		u.Host = newIP
+	}
+	return u.String()
+}
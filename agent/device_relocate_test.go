@@ -0,0 +1,128 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"printmaster/agent/agent"
+	"printmaster/agent/storage"
+)
+
+func newTestRelocator(serials map[string]string) *deviceRelocator {
+	r := newDeviceRelocator()
+	r.probeSerial = func(ctx context.Context, ip string) (string, error) {
+		if serial, ok := serials[ip]; ok {
+			return serial, nil
+		}
+		return "", errors.New("timeout")
+	}
+	r.arpTable = func() ([]agent.ARPEntry, error) {
+		return []agent.ARPEntry{
+			{IP: "10.0.0.40", MAC: "00-11-22-AA-BB-CC"},
+			{IP: "10.0.0.41", MAC: "00:11:22:33:44:55"},
+		}, nil
+	}
+	r.lookupHost = func(ctx context.Context, host string) ([]string, error) {
+		if host == "printer-3f" {
+			return []string{"10.0.0.50"}, nil
+		}
+		return nil, errors.New("no such host")
+	}
+	return r
+}
+
+func TestDeviceRelocatorFindsMovedDevice(t *testing.T) {
+	store, err := storage.NewSQLiteStore(":memory:")
+	if err != nil {
+		t.Fatalf("NewSQLiteStore: %v", err)
+	}
+	defer store.Close()
+	ctx := context.Background()
+
+	device := &storage.Device{}
+	device.Serial = "SN123"
+	device.IP = "10.0.0.9"
+	device.MACAddress = "00:11:22:aa:bb:cc"
+	device.WebUIURL = "https://10.0.0.9:8443/hp/device"
+	if err := store.Create(ctx, device); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+
+	// The old address now belongs to another printer
+	r := newTestRelocator(map[string]string{"10.0.0.9": "OTHER", "10.0.0.40": "SN123"})
+	if !r.Relocate(ctx, store, device) {
+		t.Fatal("expected device to be relocated")
+	}
+	if device.IP != "10.0.0.40" || device.WebUIURL != "https://10.0.0.40:8443/hp/device" {
+		t.Fatalf("unexpected device after relocation: ip=%s url=%s", device.IP, device.WebUIURL)
+	}
+	saved, err := store.Get(ctx, "SN123")
+	if err != nil || saved.IP != "10.0.0.40" {
+		t.Fatalf("expected saved IP 10.0.0.40, got %+v, %v", saved, err)
+	}
+}
+
+func TestDeviceRelocatorHostnameAndCooldown(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	device := &storage.Device{}
+	device.Serial = "SN456"
+	device.IP = "10.0.0.9"
+	device.Hostname = "printer-3f"
+
+	r := newTestRelocator(map[string]string{"10.0.0.50": "SN456"})
+	if !r.Relocate(ctx, nil, device) || device.IP != "10.0.0.50" {
+		t.Fatalf("expected relocation via hostname, ip=%s", device.IP)
+	}
+
+	// A second failure within the cooldown doesn't probe again
+	device.IP = "10.0.0.9"
+	if r.Relocate(ctx, nil, device) {
+		t.Fatal("expected cooldown to skip relocation")
+	}
+	r.attempts[device.Serial] = time.Now().Add(-r.cooldown)
+	if !r.Relocate(ctx, nil, device) {
+		t.Fatal("expected relocation after cooldown")
+	}
+}
+
+func TestDeviceRelocatorKeepsReachableOrLockedIP(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	device := &storage.Device{}
+	device.Serial = "SN789"
+	device.IP = "10.0.0.9"
+	device.MACAddress = "00:11:22:33:44:55"
+
+	// Serial still confirmed at the stored IP
+	r := newTestRelocator(map[string]string{"10.0.0.9": "sn789", "10.0.0.41": "SN789"})
+	if r.Relocate(ctx, nil, device) || device.IP != "10.0.0.9" {
+		t.Fatalf("expected no relocation, ip=%s", device.IP)
+	}
+
+	locked := &storage.Device{LockedFields: []storage.FieldLock{{Field: "ip"}}}
+	locked.Serial = "SN789"
+	locked.IP = "10.0.0.9"
+	locked.MACAddress = "00:11:22:33:44:55"
+	r = newTestRelocator(map[string]string{"10.0.0.41": "SN789"})
+	if r.Relocate(ctx, nil, locked) {
+		t.Fatal("expected locked IP to be left alone")
+	}
+}
+
+func TestReplaceURLHost(t *testing.T) {
+	t.Parallel()
+	cases := []struct{ in, want string }{
+		{"http://10.0.0.9", "http://10.0.0.40"},
+		{"https://10.0.0.9:8443/path", "https://10.0.0.40:8443/path"},
+		{"https://printer.local/", "https://printer.local/"},
+		{"", ""},
+	}
+	for _, c := range cases {
+		if got := replaceURLHost(c.in, "10.0.0.9", "10.0.0.40"); got != c.want {
+			t.Errorf("replaceURLHost(%q) = %q, want %q", c.in, got, c.want)
+		}
+	}
+}
@@ -0,0 +1,217 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"printmaster/agent/devicetls"
+	"printmaster/agent/storage"
+	commonutil "printmaster/common/util"
+)
+
+// deviceTLSKey stores per-device TLS overrides keyed by device IP. Client
+// certificate keys are encrypted with the agent secret key.
+const deviceTLSKey = "device_tls_overrides"
+
+var (
+	deviceTLSMu     sync.Mutex
+	deviceTLSStore  storage.AgentConfigStore
+	deviceTLSSecret []byte
+)
+
+// initDeviceTLS restores saved TLS overrides so the proxy and scrapers use
+// them from the first request.
+func initDeviceTLS(store storage.AgentConfigStore, secret []byte) {
+	deviceTLSMu.Lock()
+	deviceTLSStore = store
+	deviceTLSSecret = secret
+	deviceTLSMu.Unlock()
+	if store == nil {
+		return
+	}
+	var saved map[string]devicetls.Options
+	if err := store.GetConfigValue(deviceTLSKey, &saved); err != nil || len(saved) == 0 {
+		return
+	}
+	for ip, o := range saved {
+		if o.ClientKey == "" {
+			continue
+		}
+		key, err := commonutil.DecryptFromB64(secret, o.ClientKey)
+		if err != nil {
+			if appLogger != nil {
+				appLogger.Warn("Could not decrypt device TLS client key; certificate disabled", "ip", ip, "error", err)
+			}
+			o.ClientCert, o.ClientKey = "", ""
+		} else {
+			o.ClientKey = key
+		}
+		saved[ip] = o
+	}
+	errs := devicetls.Load(saved)
+	if appLogger == nil {
+		return
+	}
+	for _, err := range errs {
+		appLogger.Warn("Ignoring invalid device TLS override", "error", err)
+	}
+	for ip, o := range devicetls.All() {
+		logDeviceTLSWarnings(ip, o)
+	}
+	appLogger.Info("Loaded device TLS overrides", "devices", len(devicetls.All()))
+}
+
+func logDeviceTLSWarnings(ip string, o devicetls.Options) {
+	if appLogger == nil {
+		return
+	}
+	for _, w := range o.Warnings() {
+		appLogger.Warn("Weakened TLS enabled for device", "ip", ip, "warning", w)
+	}
+}
+
+func saveDeviceTLS() error {
+	deviceTLSMu.Lock()
+	defer deviceTLSMu.Unlock()
+	if deviceTLSStore == nil {
+		return nil
+	}
+	all := devicetls.All()
+	for ip, o := range all {
+		if o.ClientKey == "" {
+			continue
+		}
+		enc, err := commonutil.EncryptToB64(deviceTLSSecret, o.ClientKey)
+		if err != nil {
+			return err
+		}
+		o.ClientKey = enc
+		all[ip] = o
+	}
+	return deviceTLSStore.SetConfigValue(deviceTLSKey, all)
+}
+
+type deviceTLSRequest struct {
+	IP     string `json:"ip,omitempty"`
+	Serial string `json:"serial,omitempty"`
+	devicetls.Options
+	// RemoveClientCert drops a saved certificate; otherwise an empty
+	// certificate in the request keeps the saved one.
+	RemoveClientCert bool `json:"remove_client_cert,omitempty"`
+}
+
+// deviceTLSView is an override as reported by the API: the key and
+// certificate themselves are never sent back.
+type deviceTLSView struct {
+	IP            string   `json:"ip"`
+	MinVersion    string   `json:"min_version,omitempty"`
+	LegacyCiphers bool     `json:"legacy_ciphers,omitempty"`
+	HasClientCert bool     `json:"has_client_cert"`
+	Warnings      []string `json:"warnings,omitempty"`
+}
+
+func newDeviceTLSView(ip string, o devicetls.Options) deviceTLSView {
+	return deviceTLSView{IP: ip, MinVersion: o.MinVersion, LegacyCiphers: o.LegacyCiphers, HasClientCert: o.HasClientCert(), Warnings: o.Warnings()}
+}
+
+// registerDeviceTLSHandlers exposes per-device TLS overrides.
+func registerDeviceTLSHandlers() {
+	// GET  /api/devices/tls - list overrides keyed by IP (?ip= or ?serial= for one device)
+	// POST /api/devices/tls - set the override for a device; default options clear it
+	http.HandleFunc("/api/devices/tls", handleDeviceTLS)
+}
+
+func handleDeviceTLS(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		w.Header().Set("Content-Type", "application/json")
+		q := r.URL.Query()
+		if q.Get("ip") != "" || q.Get("serial") != "" {
+			ip, status, msg := resolveDeviceIP(r.Context(), q.Get("ip"), q.Get("serial"))
+			if status != 0 {
+				http.Error(w, msg, status)
+				return
+			}
+			o, _ := devicetls.For(ip)
+			json.NewEncoder(w).Encode(newDeviceTLSView(ip, o))
+			return
+		}
+		out := make(map[string]deviceTLSView)
+		for ip, o := range devicetls.All() {
+			out[ip] = newDeviceTLSView(ip, o)
+		}
+		json.NewEncoder(w).Encode(out)
+	case http.MethodPost:
+		var req deviceTLSRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "bad json", http.StatusBadRequest)
+			return
+		}
+		ip, status, msg := resolveDeviceIP(r.Context(), req.IP, req.Serial)
+		if status != 0 {
+			http.Error(w, msg, status)
+			return
+		}
+		opts := req.Options
+		opts.MinVersion = strings.TrimSpace(opts.MinVersion)
+		if opts.ClientCert == "" && opts.ClientKey == "" && !req.RemoveClientCert {
+			if cur, ok := devicetls.For(ip); ok {
+				opts.ClientCert, opts.ClientKey = cur.ClientCert, cur.ClientKey
+			}
+		}
+		deviceTLSMu.Lock()
+		haveSecret := len(deviceTLSSecret) == 32
+		deviceTLSMu.Unlock()
+		if opts.ClientKey != "" && !haveSecret {
+			http.Error(w, "secret key unavailable; cannot store a client certificate", http.StatusInternalServerError)
+			return
+		}
+		changed, err := devicetls.Set(ip, opts)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if changed {
+			if err := saveDeviceTLS(); err != nil {
+				if appLogger != nil {
+					appLogger.Warn("Failed to save device TLS overrides", "error", err)
+				}
+				http.Error(w, "failed to save override", http.StatusInternalServerError)
+				return
+			}
+			if appLogger != nil {
+				appLogger.Info("Device TLS override updated", "ip", ip, "min_version", opts.MinVersion,
+					"legacy_ciphers", opts.LegacyCiphers, "client_cert", opts.HasClientCert())
+			}
+			logDeviceTLSWarnings(ip, opts)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(newDeviceTLSView(ip, opts))
+	default:
+		http.Error(w, "GET or POST only", http.StatusMethodNotAllowed)
+	}
+}
+
+// resolveDeviceIP returns ip, or the IP of the saved device with serial. A
+// non-zero status reports why neither identifies a device.
+func resolveDeviceIP(ctx context.Context, ip, serial string) (string, int, string) {
+	ip = strings.TrimSpace(ip)
+	if ip == "" && serial != "" && deviceStore != nil {
+		ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+		dev, err := deviceStore.Get(ctx, serial)
+		cancel()
+		if err != nil {
+			return "", http.StatusNotFound, "device not found"
+		}
+		ip = dev.IP
+	}
+	if net.ParseIP(ip) == nil {
+		return "", http.StatusBadRequest, "ip or serial of a network device required"
+	}
+	return ip, 0, ""
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"printmaster/agent/devicetls"
+)
+
+func TestDeviceTLSHandlerPersistsOverrides(t *testing.T) {
+	store := newFakeConfigStore()
+	secret := []byte("0123456789abcdef0123456789abcdef")
+	initDeviceTLS(store, secret)
+	t.Cleanup(func() {
+		devicetls.Load(nil)
+		initDeviceTLS(nil, nil)
+	})
+
+	post := func(body string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		handleDeviceTLS(rec, httptest.NewRequest(http.MethodPost, "/api/devices/tls", strings.NewReader(body)))
+		return rec
+	}
+
+	if rec := post(`{"ip":"10.0.0.9","min_version":"0.9"}`); rec.Code != http.StatusBadRequest {
+		t.Fatalf("invalid version: status %d", rec.Code)
+	}
+	rec := post(`{"ip":"10.0.0.9","min_version":"1.0","legacy_ciphers":true}`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("POST: status %d body %s", rec.Code, rec.Body.String())
+	}
+	var view deviceTLSView
+	if err := json.Unmarshal(rec.Body.Bytes(), &view); err != nil || len(view.Warnings) != 2 {
+		t.Fatalf("expected two warnings, got %+v (%v)", view, err)
+	}
+
+	// Reloading from the store restores the override
+	devicetls.Load(nil)
+	initDeviceTLS(store, secret)
+	if o, ok := devicetls.For("10.0.0.9"); !ok || o.MinVersion != "1.0" || !o.LegacyCiphers {
+		t.Fatalf("override not restored: %+v %v", o, ok)
+	}
+
+	if rec := post(`{"ip":"10.0.0.9"}`); rec.Code != http.StatusOK {
+		t.Fatalf("clear: status %d", rec.Code)
+	}
+	if _, ok := devicetls.For("10.0.0.9"); ok {
+		t.Fatal("default options should clear the override")
+	}
+}
@@ -0,0 +1,451 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"printmaster/agent/storage"
+)
+
+// Watch mode polls one device at a high rate for a limited time, to catch an
+// intermittent problem (a printer dropping off the network, a counter that
+// jumps) that the normal metrics schedule is too coarse to see. Every sample
+// is stored in the raw tier and pushed to the UI over SSE; the store keeps
+// samples taken during a watch past normal raw retention.
+const (
+	defaultWatchInterval = 30 * time.Second
+	minWatchInterval     = 10 * time.Second
+	maxWatchInterval     = 10 * time.Minute
+	defaultWatchDuration = 2 * time.Hour
+	maxWatchDuration     = 24 * time.Hour
+)
+
+var errWatchUnavailable = errors.New("device watches are not supported by this store")
+
+// activeWatch is a watch with its polling goroutine.
+type activeWatch struct {
+	watch  *storage.DeviceWatch
+	cancel context.CancelFunc
+}
+
+// deviceWatchManager runs at most one watch per device.
+type deviceWatchManager struct {
+	now  func() time.Time
+	poll func(ctx context.Context, serial string) (*storage.MetricsSnapshot, error)
+
+	mu      sync.Mutex
+	store   storage.DeviceWatchStore
+	metrics storage.DeviceStore
+	ctx     context.Context
+	active  map[string]*activeWatch // serial -> running watch
+}
+
+var deviceWatches = newDeviceWatchManager()
+
+func newDeviceWatchManager() *deviceWatchManager {
+	return &deviceWatchManager{
+		now:    time.Now,
+		poll:   pollWatchedDevice,
+		ctx:    context.Background(),
+		active: make(map[string]*activeWatch),
+	}
+}
+
+// initDeviceWatches connects the manager to the device store and resumes
+// watches that were running when the agent stopped. Watches that expired
+// in the meantime are closed.
+func initDeviceWatches(ctx context.Context, store storage.DeviceStore) {
+	deviceWatches.attach(ctx, store)
+	deviceWatches.resume()
+}
+
+func (m *deviceWatchManager) attach(ctx context.Context, store storage.DeviceStore) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.ctx = ctx
+	m.metrics = store
+	m.store, _ = store.(storage.DeviceWatchStore)
+}
+
+func (m *deviceWatchManager) resume() {
+	m.mu.Lock()
+	store := m.store
+	m.mu.Unlock()
+	if store == nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	open, err := store.ListOpenDeviceWatches(ctx)
+	if err != nil {
+		if appLogger != nil {
+			appLogger.Warn("Failed to load device watches", "error", err)
+		}
+		return
+	}
+	for _, w := range open {
+		if !m.now().Before(w.ExpiresAt) {
+			m.end(w, storage.DeviceWatchExpired)
+			continue
+		}
+		m.mu.Lock()
+		m.launchLocked(w)
+		m.mu.Unlock()
+		if appLogger != nil {
+			appLogger.Info("Resumed device watch", "serial", w.Serial, "expires_at", w.ExpiresAt)
+		}
+	}
+}
+
+// start begins watching serial, replacing any watch already running for it.
+func (m *deviceWatchManager) start(serial string, interval, duration time.Duration) (*storage.DeviceWatch, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.store == nil {
+		return nil, errWatchUnavailable
+	}
+	if prev := m.active[serial]; prev != nil {
+		prev.cancel()
+		delete(m.active, serial)
+		m.endLocked(prev.watch, storage.DeviceWatchStopped)
+	}
+
+	now := m.now()
+	w := &storage.DeviceWatch{
+		Serial:          serial,
+		IntervalSeconds: int(interval / time.Second),
+		StartedAt:       now,
+		ExpiresAt:       now.Add(duration),
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := m.store.AddDeviceWatch(ctx, w); err != nil {
+		return nil, err
+	}
+	m.launchLocked(w)
+	if appLogger != nil {
+		appLogger.Info("Device watch started", "serial", serial, "interval", interval, "duration", duration)
+	}
+	broadcastDeviceWatch("device_watch_started", w)
+	return cloneDeviceWatch(w), nil
+}
+
+// stop ends the watch on serial. Returns false if none was running.
+func (m *deviceWatchManager) stop(serial string) (*storage.DeviceWatch, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	aw := m.active[serial]
+	if aw == nil {
+		return nil, false
+	}
+	aw.cancel()
+	delete(m.active, serial)
+	m.endLocked(aw.watch, storage.DeviceWatchStopped)
+	return cloneDeviceWatch(aw.watch), true
+}
+
+// list returns the running watches ordered by serial.
+func (m *deviceWatchManager) list() []*storage.DeviceWatch {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	out := make([]*storage.DeviceWatch, 0, len(m.active))
+	for _, aw := range m.active {
+		out = append(out, cloneDeviceWatch(aw.watch))
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Serial < out[j].Serial })
+	return out
+}
+
+func (m *deviceWatchManager) launchLocked(w *storage.DeviceWatch) {
+	ctx, cancel := context.WithDeadline(m.ctx, w.ExpiresAt)
+	aw := &activeWatch{watch: w, cancel: cancel}
+	m.active[w.Serial] = aw
+	go m.run(ctx, aw)
+}
+
+// run polls until the watch expires or is stopped. Stopping cancels ctx and
+// ends the watch in stop; expiry ends it here.
+func (m *deviceWatchManager) run(ctx context.Context, aw *activeWatch) {
+	defer aw.cancel()
+	ticker := time.NewTicker(time.Duration(aw.watch.IntervalSeconds) * time.Second)
+	defer ticker.Stop()
+	for {
+		m.sample(ctx, aw)
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+				m.mu.Lock()
+				if m.active[aw.watch.Serial] == aw {
+					delete(m.active, aw.watch.Serial)
+					m.endLocked(aw.watch, storage.DeviceWatchExpired)
+				}
+				m.mu.Unlock()
+			}
+			return
+		}
+	}
+}
+
+// sample takes one reading, stores it in the raw tier and streams it.
+func (m *deviceWatchManager) sample(ctx context.Context, aw *activeWatch) {
+	serial := aw.watch.Serial
+	timeout := time.Duration(aw.watch.IntervalSeconds) * time.Second
+	if timeout > 20*time.Second {
+		timeout = 20 * time.Second
+	}
+	pollCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	started := m.now()
+	snapshot, err := m.poll(pollCtx, serial)
+	latency := m.now().Sub(started)
+	if ctx.Err() != nil {
+		return // stopped or expired mid-poll
+	}
+	if err == nil {
+		snapshot.Serial = serial
+		snapshot.Timestamp = started
+		saveCtx, saveCancel := context.WithTimeout(context.Background(), 5*time.Second)
+		err = m.metrics.SaveMetricsSnapshot(saveCtx, snapshot)
+		saveCancel()
+	}
+
+	m.mu.Lock()
+	aw.watch.Samples++
+	if err != nil {
+		aw.watch.Failures++
+		aw.watch.LastError = err.Error()
+	}
+	w := cloneDeviceWatch(aw.watch)
+	if m.active[serial] == aw {
+		m.saveLocked(aw.watch)
+	}
+	m.mu.Unlock()
+
+	data := map[string]interface{}{
+		"watch_id":   w.ID,
+		"serial":     serial,
+		"timestamp":  started,
+		"ok":         err == nil,
+		"latency_ms": latency.Milliseconds(),
+		"samples":    w.Samples,
+		"failures":   w.Failures,
+	}
+	if err != nil {
+		data["error"] = err.Error()
+		if appLogger != nil {
+			appLogger.WarnRateLimited("device_watch_"+serial, time.Minute, "Device watch: poll failed", "serial", serial, "error", err)
+		}
+	} else {
+		data["page_count"] = snapshot.PageCount
+		data["color_pages"] = snapshot.ColorPages
+		data["mono_pages"] = snapshot.MonoPages
+		data["scan_count"] = snapshot.ScanCount
+		data["jam_events"] = snapshot.JamEvents
+		data["toner_levels"] = snapshot.TonerLevels
+	}
+	if sseHub != nil {
+		sseHub.Broadcast(SSEEvent{Type: "device_watch_sample", Data: data})
+	}
+}
+
+func (m *deviceWatchManager) end(w *storage.DeviceWatch, reason string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.endLocked(w, reason)
+}
+
+func (m *deviceWatchManager) endLocked(w *storage.DeviceWatch, reason string) {
+	now := m.now()
+	if now.After(w.ExpiresAt) {
+		now = w.ExpiresAt
+	}
+	w.EndedAt = &now
+	w.EndReason = reason
+	m.saveLocked(w)
+	if appLogger != nil {
+		appLogger.Info("Device watch ended", "serial", w.Serial, "reason", reason, "samples", w.Samples, "failures", w.Failures)
+	}
+	broadcastDeviceWatch("device_watch_ended", w)
+}
+
+func (m *deviceWatchManager) saveLocked(w *storage.DeviceWatch) {
+	if m.store == nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := m.store.UpdateDeviceWatch(ctx, w); err != nil && appLogger != nil {
+		appLogger.Warn("Failed to save device watch", "serial", w.Serial, "error", err)
+	}
+}
+
+func cloneDeviceWatch(w *storage.DeviceWatch) *storage.DeviceWatch {
+	out := *w
+	if w.EndedAt != nil {
+		ended := *w.EndedAt
+		out.EndedAt = &ended
+	}
+	return &out
+}
+
+func broadcastDeviceWatch(eventType string, w *storage.DeviceWatch) {
+	if sseHub != nil {
+		data := map[string]interface{}{
+			"watch_id":         w.ID,
+			"serial":           w.Serial,
+			"interval_seconds": w.IntervalSeconds,
+			"started_at":       w.StartedAt,
+			"expires_at":       w.ExpiresAt,
+			"samples":          w.Samples,
+			"failures":         w.Failures,
+		}
+		if w.EndedAt != nil {
+			data["ended_at"] = *w.EndedAt
+			data["end_reason"] = w.EndReason
+		}
+		sseHub.Broadcast(SSEEvent{Type: eventType, Data: data})
+	}
+}
+
+// pollWatchedDevice reads a device's counters the way the metrics schedule
+// does, looking up the device each time so a changed IP is picked up.
+func pollWatchedDevice(ctx context.Context, serial string) (*storage.MetricsSnapshot, error) {
+	if deviceStore == nil {
+		return nil, errors.New("device store not available")
+	}
+	device, err := deviceStore.Get(ctx, serial)
+	if err != nil {
+		return nil, err
+	}
+	if device.DeviceType == "usb" || device.IsUSB {
+		return CollectUSBMetricsSnapshot(ctx, serial)
+	}
+	pi := storage.DeviceToPrinterInfo(device)
+	agentSnapshot, err := CollectMetricsWithOIDs(ctx, device.IP, serial, device.Manufacturer, 10, &pi.LearnedOIDs)
+	if err != nil {
+		return nil, err
+	}
+	return metricsSnapshotFromAgent(agentSnapshot), nil
+}
+
+type deviceWatchRequest struct {
+	Serial          string `json:"serial"`
+	IntervalSeconds int    `json:"interval_seconds"`
+	DurationMinutes int    `json:"duration_minutes"`
+}
+
+// registerDeviceWatchHandlers exposes watch mode.
+func registerDeviceWatchHandlers() {
+	// GET  /api/devices/watch?serial= - running watches and recent history
+	// POST /api/devices/watch - {"serial", "interval_seconds", "duration_minutes"} start or restart a watch
+	http.HandleFunc("/api/devices/watch", func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+			serial := strings.TrimSpace(r.URL.Query().Get("serial"))
+			active := deviceWatches.list()
+			if serial != "" {
+				filtered := active[:0]
+				for _, aw := range active {
+					if aw.Serial == serial {
+						filtered = append(filtered, aw)
+					}
+				}
+				active = filtered
+			}
+			recent := []*storage.DeviceWatch{}
+			if store, _ := deviceStore.(storage.DeviceWatchStore); store != nil {
+				ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+				defer cancel()
+				if list, err := store.ListDeviceWatches(ctx, serial, 20); err == nil && list != nil {
+					recent = list
+				}
+			}
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(map[string]interface{}{"active": active, "recent": recent})
+		case http.MethodPost:
+			var req deviceWatchRequest
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+				http.Error(w, "bad json", http.StatusBadRequest)
+				return
+			}
+			req.Serial = strings.TrimSpace(req.Serial)
+			if req.Serial == "" {
+				http.Error(w, "serial required", http.StatusBadRequest)
+				return
+			}
+			interval := defaultWatchInterval
+			if req.IntervalSeconds != 0 {
+				interval = time.Duration(req.IntervalSeconds) * time.Second
+			}
+			duration := defaultWatchDuration
+			if req.DurationMinutes != 0 {
+				duration = time.Duration(req.DurationMinutes) * time.Minute
+			}
+			if interval < minWatchInterval || interval > maxWatchInterval {
+				http.Error(w, "interval_seconds must be between "+strconv.Itoa(int(minWatchInterval/time.Second))+
+					" and "+strconv.Itoa(int(maxWatchInterval/time.Second)), http.StatusBadRequest)
+				return
+			}
+			if duration < interval || duration > maxWatchDuration {
+				http.Error(w, "duration_minutes must cover at least one interval and at most "+
+					strconv.Itoa(int(maxWatchDuration/time.Minute)), http.StatusBadRequest)
+				return
+			}
+			if deviceStore == nil {
+				http.Error(w, "device store not available", http.StatusServiceUnavailable)
+				return
+			}
+			ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+			_, err := deviceStore.Get(ctx, req.Serial)
+			cancel()
+			if err != nil {
+				http.Error(w, "device not found", http.StatusNotFound)
+				return
+			}
+			watch, err := deviceWatches.start(req.Serial, interval, duration)
+			if errors.Is(err, errWatchUnavailable) {
+				http.Error(w, err.Error(), http.StatusNotImplemented)
+				return
+			}
+			if err != nil {
+				appLogger.Error("Failed to start device watch", "serial", req.Serial, "error", err)
+				http.Error(w, "failed to start watch: "+err.Error(), http.StatusInternalServerError)
+				return
+			}
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusCreated)
+			json.NewEncoder(w).Encode(watch)
+		default:
+			http.Error(w, "GET or POST only", http.StatusMethodNotAllowed)
+		}
+	})
+
+	// POST /api/devices/watch/stop - {"serial": "..."} end a watch early
+	http.HandleFunc("/api/devices/watch/stop", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "POST only", http.StatusMethodNotAllowed)
+			return
+		}
+		var req deviceWatchRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || strings.TrimSpace(req.Serial) == "" {
+			http.Error(w, "serial required", http.StatusBadRequest)
+			return
+		}
+		watch, ok := deviceWatches.stop(strings.TrimSpace(req.Serial))
+		if !ok {
+			http.Error(w, "no watch running for "+req.Serial, http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(watch)
+	})
+}
@@ -0,0 +1,146 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"printmaster/agent/storage"
+)
+
+func newTestWatchManager(t *testing.T) (*deviceWatchManager, *storage.SQLiteStore) {
+	t.Helper()
+	// A file database: the watch loop writes from its own goroutine, and each
+	// pooled connection to ":memory:" would see a separate empty database
+	store, err := storage.NewSQLiteStore(filepath.Join(t.TempDir(), "agent.db"))
+	if err != nil {
+		t.Fatalf("NewSQLiteStore: %v", err)
+	}
+	t.Cleanup(func() { store.Close() })
+	dev := &storage.Device{}
+	dev.Serial, dev.IP = "W1", "10.0.0.5"
+	if err := store.Create(context.Background(), dev); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+
+	var polls atomic.Int32
+	m := newDeviceWatchManager()
+	m.poll = func(ctx context.Context, serial string) (*storage.MetricsSnapshot, error) {
+		n := int(polls.Add(1))
+		if n == 2 {
+			return nil, errors.New("timeout")
+		}
+		snap := &storage.MetricsSnapshot{}
+		snap.PageCount = 1000 + n
+		return snap, nil
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	m.attach(ctx, store)
+	return m, store
+}
+
+func waitForWatchEnd(t *testing.T, store *storage.SQLiteStore, id int64) *storage.DeviceWatch {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		list, err := store.ListDeviceWatches(context.Background(), "", 0)
+		if err != nil {
+			t.Fatalf("ListDeviceWatches: %v", err)
+		}
+		for _, w := range list {
+			if w.ID == id && w.EndedAt != nil {
+				return w
+			}
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	t.Fatalf("watch %d did not end", id)
+	return nil
+}
+
+func TestDeviceWatchPollsUntilExpiry(t *testing.T) {
+	t.Parallel()
+	m, store := newTestWatchManager(t)
+
+	w, err := m.start("W1", time.Second, 2500*time.Millisecond)
+	if err != nil {
+		t.Fatalf("start: %v", err)
+	}
+	if len(m.list()) != 1 {
+		t.Fatalf("expected one running watch")
+	}
+	ended := waitForWatchEnd(t, store, w.ID)
+	if ended.EndReason != storage.DeviceWatchExpired {
+		t.Errorf("end reason = %q, want expired", ended.EndReason)
+	}
+	if ended.Samples < 2 || ended.Failures != 1 || ended.LastError != "timeout" {
+		t.Errorf("watch = %+v, want at least 2 samples with 1 failure", ended)
+	}
+	if len(m.list()) != 0 {
+		t.Errorf("expired watch still listed")
+	}
+
+	latest, err := store.GetLatestMetrics(context.Background(), "W1")
+	if err != nil || latest == nil || latest.PageCount < 1003 {
+		t.Errorf("latest metrics = %+v, %v; want the watch samples stored", latest, err)
+	}
+}
+
+func TestDeviceWatchStopAndRestart(t *testing.T) {
+	t.Parallel()
+	m, store := newTestWatchManager(t)
+
+	first, err := m.start("W1", time.Minute, time.Hour)
+	if err != nil {
+		t.Fatalf("start: %v", err)
+	}
+	// Starting again replaces the running watch
+	second, err := m.start("W1", 30*time.Second, time.Hour)
+	if err != nil {
+		t.Fatalf("restart: %v", err)
+	}
+	if got := waitForWatchEnd(t, store, first.ID); got.EndReason != storage.DeviceWatchStopped {
+		t.Errorf("replaced watch end reason = %q", got.EndReason)
+	}
+	if active := m.list(); len(active) != 1 || active[0].ID != second.ID || active[0].IntervalSeconds != 30 {
+		t.Errorf("active = %+v", active)
+	}
+
+	if _, ok := m.stop("W1"); !ok {
+		t.Fatal("stop: no watch")
+	}
+	if got := waitForWatchEnd(t, store, second.ID); got.EndReason != storage.DeviceWatchStopped {
+		t.Errorf("stopped watch end reason = %q", got.EndReason)
+	}
+	if _, ok := m.stop("W1"); ok {
+		t.Error("second stop found a watch")
+	}
+}
+
+func TestDeviceWatchResume(t *testing.T) {
+	t.Parallel()
+	m, store := newTestWatchManager(t)
+	ctx := context.Background()
+
+	now := time.Now()
+	stale := &storage.DeviceWatch{Serial: "W1", IntervalSeconds: 30, StartedAt: now.Add(-3 * time.Hour), ExpiresAt: now.Add(-time.Hour)}
+	live := &storage.DeviceWatch{Serial: "W1", IntervalSeconds: 30, StartedAt: now.Add(-time.Minute), ExpiresAt: now.Add(time.Hour)}
+	for _, w := range []*storage.DeviceWatch{stale, live} {
+		if err := store.AddDeviceWatch(ctx, w); err != nil {
+			t.Fatalf("AddDeviceWatch: %v", err)
+		}
+	}
+
+	m.resume()
+	if got := waitForWatchEnd(t, store, stale.ID); got.EndReason != storage.DeviceWatchExpired || !got.EndedAt.Equal(stale.ExpiresAt) {
+		t.Errorf("stale watch = %+v, want ended at its expiry", got)
+	}
+	if active := m.list(); len(active) != 1 || active[0].ID != live.ID {
+		t.Errorf("active = %+v, want the live watch resumed", active)
+	}
+	m.stop("W1")
+}
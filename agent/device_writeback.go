@@ -0,0 +1,422 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"printmaster/agent/agent"
+	"printmaster/agent/scanscope"
+	"printmaster/agent/storage"
+
+	"github.com/gosnmp/gosnmp"
+)
+
+// Write-back pushes the asset number and location stored in PrintMaster
+// into the devices themselves, so that sysLocation/sysContact and the
+// control panel match the inventory.
+
+const (
+	writebackMaxDevices   = 500
+	writebackConcurrency  = 4
+	writebackPanelDefault = 40  // characters most panels show on the ready line
+	snmpDisplayStringMax  = 255 // RFC 2579 DisplayString limit
+	sysContactOID         = ".1.3.6.1.2.1.1.4.0"
+	sysLocationOID        = ".1.3.6.1.2.1.1.6.0"
+)
+
+// Write-back targets.
+const (
+	writebackLocation = "location" // SNMP sysLocation
+	writebackContact  = "contact"  // SNMP sysContact
+	writebackPanel    = "panel"    // PJL ready message on the control panel
+)
+
+// writebackTemplates are the default values written for each target.
+var writebackTemplates = map[string]string{
+	writebackLocation: "{location}",
+	writebackContact:  "{asset_number}",
+	writebackPanel:    "{asset_number} {location}",
+}
+
+// writebackRequest selects devices and what to write into them. Templates
+// may use {asset_number}, {location}, {serial}, {model} and {hostname}.
+type writebackRequest struct {
+	Serials          []string `json:"serials,omitempty"`
+	AllSaved         bool     `json:"all_saved,omitempty"`
+	Targets          []string `json:"targets"`
+	LocationTemplate string   `json:"location_template,omitempty"`
+	ContactTemplate  string   `json:"contact_template,omitempty"`
+	PanelTemplate    string   `json:"panel_template,omitempty"`
+	// WriteCommunity is the SNMPv1/v2c community with write access. It is
+	// used for this request only and never stored or logged.
+	WriteCommunity string `json:"write_community,omitempty"`
+	// ASCIIOnly transliterates SNMP values for devices that reject UTF-8.
+	// Panel messages are always transliterated.
+	ASCIIOnly      bool `json:"ascii_only,omitempty"`
+	PanelMaxLength int  `json:"panel_max_length,omitempty"`
+	DryRun         bool `json:"dry_run,omitempty"`
+}
+
+// writebackResult reports what was written to one device.
+type writebackResult struct {
+	Serial  string            `json:"serial"`
+	IP      string            `json:"ip,omitempty"`
+	Values  map[string]string `json:"values"`
+	Written []string          `json:"written,omitempty"`
+	Skipped []string          `json:"skipped,omitempty"` // targets whose value rendered empty
+	Error   string            `json:"error,omitempty"`
+}
+
+// sendPJL delivers a PJL job to the device's raw printing port. Targets
+// outside the authorized scan scope are refused. Tests can replace it.
+var sendPJL = func(ctx context.Context, ip string, payload []byte) error {
+	if err := scanscope.Check(ip, "writeback"); err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "tcp", net.JoinHostPort(ip, "9100"))
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetWriteDeadline(deadline)
+	}
+	_, err = conn.Write(payload)
+	return err
+}
+
+func (req *writebackRequest) normalize() error {
+	seen := map[string]bool{}
+	targets := make([]string, 0, len(req.Targets))
+	for _, t := range req.Targets {
+		t = strings.ToLower(strings.TrimSpace(t))
+		if _, ok := writebackTemplates[t]; !ok {
+			return fmt.Errorf("unknown target %q (want location, contact or panel)", t)
+		}
+		if !seen[t] {
+			seen[t] = true
+			targets = append(targets, t)
+		}
+	}
+	if len(targets) == 0 {
+		return fmt.Errorf("at least one target is required")
+	}
+	req.Targets = targets
+	if req.PanelMaxLength <= 0 {
+		req.PanelMaxLength = writebackPanelDefault
+	}
+	if len(req.Serials) == 0 && !req.AllSaved {
+		return fmt.Errorf("serials or all_saved is required")
+	}
+	return nil
+}
+
+func (req *writebackRequest) template(target string) string {
+	var t string
+	switch target {
+	case writebackLocation:
+		t = req.LocationTemplate
+	case writebackContact:
+		t = req.ContactTemplate
+	case writebackPanel:
+		t = req.PanelTemplate
+	}
+	if strings.TrimSpace(t) == "" {
+		return writebackTemplates[target]
+	}
+	return t
+}
+
+// renderWriteback fills a template from the device's inventory values.
+func renderWriteback(template string, device *storage.Device) string {
+	r := strings.NewReplacer(
+		"{asset_number}", device.AssetNumber,
+		"{location}", device.Location,
+		"{serial}", device.Serial,
+		"{model}", device.Model,
+		"{hostname}", device.Hostname,
+	)
+	return strings.Join(strings.Fields(r.Replace(template)), " ")
+}
+
+// writebackValues renders each target's value, applying the limits and
+// character set the target supports.
+func writebackValues(req *writebackRequest, device *storage.Device) map[string]string {
+	values := make(map[string]string, len(req.Targets))
+	for _, target := range req.Targets {
+		v := renderWriteback(req.template(target), device)
+		if target == writebackPanel {
+			v = truncateRunes(panelText(v), req.PanelMaxLength)
+		} else {
+			if req.ASCIIOnly {
+				v = asciiFold(v)
+			}
+			v = truncateBytes(v, snmpDisplayStringMax)
+		}
+		values[target] = strings.TrimSpace(v)
+	}
+	return values
+}
+
+// writebackDevice writes the rendered values into one device.
+func writebackDevice(ctx context.Context, cfg *agent.SNMPConfig, req *writebackRequest, device *storage.Device) writebackResult {
+	res := writebackResult{Serial: device.Serial, IP: device.IP, Values: writebackValues(req, device)}
+	if device.IsUSB || device.IP == "" {
+		res.Error = "device has no network address"
+		return res
+	}
+
+	var pdus []gosnmp.SnmpPDU
+	var snmpTargets []string
+	for _, target := range req.Targets {
+		value := res.Values[target]
+		if value == "" {
+			res.Skipped = append(res.Skipped, target)
+			continue
+		}
+		switch target {
+		case writebackLocation:
+			pdus = append(pdus, gosnmp.SnmpPDU{Name: sysLocationOID, Type: gosnmp.OctetString, Value: value})
+			snmpTargets = append(snmpTargets, target)
+		case writebackContact:
+			pdus = append(pdus, gosnmp.SnmpPDU{Name: sysContactOID, Type: gosnmp.OctetString, Value: value})
+			snmpTargets = append(snmpTargets, target)
+		}
+	}
+	if req.DryRun {
+		return res
+	}
+
+	var errs []string
+	if len(pdus) > 0 {
+		if err := writebackSNMP(cfg, device.IP, req.WriteCommunity, pdus); err != nil {
+			errs = append(errs, "snmp: "+err.Error())
+		} else {
+			res.Written = append(res.Written, snmpTargets...)
+		}
+	}
+	if panel := res.Values[writebackPanel]; panel != "" {
+		if err := sendPJL(ctx, device.IP, pjlReadyMessage(panel)); err != nil {
+			errs = append(errs, "pjl: "+err.Error())
+		} else {
+			res.Written = append(res.Written, writebackPanel)
+		}
+	}
+	res.Error = strings.Join(errs, "; ")
+	return res
+}
+
+func writebackSNMP(cfg *agent.SNMPConfig, ip, community string, pdus []gosnmp.SnmpPDU) error {
+	client, err := agent.NewSNMPSetter(cfg, ip, community, 10)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+	packet, err := client.Set(pdus)
+	if err != nil {
+		return err
+	}
+	if packet != nil && packet.Error != gosnmp.NoError {
+		return fmt.Errorf("device rejected SET: %v (check the write community or v3 user access)", packet.Error)
+	}
+	return nil
+}
+
+// pjlReadyMessage builds a PJL job that sets the panel's ready message.
+func pjlReadyMessage(text string) []byte {
+	const uel = "\x1b%-12345X"
+	return []byte(uel + "@PJL\r\n@PJL RDYMSG DISPLAY = \"" + text + "\"\r\n" + uel)
+}
+
+// panelText makes text safe for a PJL string: ASCII only, no quotes or
+// control characters.
+func panelText(s string) string {
+	s = asciiFold(s)
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r == '"':
+			return '\''
+		case r < 0x20 || r == 0x7f:
+			return -1
+		}
+		return r
+	}, s)
+}
+
+// asciiFoldMap transliterates common Latin letters with diacritics.
+var asciiFoldMap = map[rune]string{
+	'à': "a", 'á': "a", 'â': "a", 'ã': "a", 'ä': "a", 'å': "a", 'æ': "ae", 'ą': "a",
+	'À': "A", 'Á': "A", 'Â': "A", 'Ã': "A", 'Ä': "A", 'Å': "A", 'Æ': "AE", 'Ą': "A",
+	'ç': "c", 'ć': "c", 'č': "c", 'Ç': "C", 'Ć': "C", 'Č': "C",
+	'ď': "d", 'đ': "d", 'Ď': "D", 'Đ': "D",
+	'è': "e", 'é': "e", 'ê': "e", 'ë': "e", 'ę': "e", 'ě': "e",
+	'È': "E", 'É': "E", 'Ê': "E", 'Ë': "E", 'Ę': "E", 'Ě': "E",
+	'ì': "i", 'í': "i", 'î': "i", 'ï': "i", 'Ì': "I", 'Í': "I", 'Î': "I", 'Ï': "I",
+	'ł': "l", 'Ł': "L", 'ñ': "n", 'ń': "n", 'ň': "n", 'Ñ': "N", 'Ń': "N", 'Ň': "N",
+	'ò': "o", 'ó': "o", 'ô': "o", 'õ': "o", 'ö': "o", 'ø': "o", 'ő': "o", 'œ': "oe",
+	'Ò': "O", 'Ó': "O", 'Ô': "O", 'Õ': "O", 'Ö': "O", 'Ø': "O", 'Ő': "O", 'Œ': "OE",
+	'ř': "r", 'Ř': "R", 'ś': "s", 'š': "s", 'ß': "ss", 'Ś': "S", 'Š': "S",
+	'ť': "t", 'Ť': "T", 'ù': "u", 'ú': "u", 'û': "u", 'ü': "u", 'ů': "u", 'ű': "u",
+	'Ù': "U", 'Ú': "U", 'Û': "U", 'Ü': "U", 'Ů': "U", 'Ű': "U",
+	'ý': "y", 'ÿ': "y", 'Ý': "Y", 'ź': "z", 'ż': "z", 'ž': "z", 'Ź': "Z", 'Ż': "Z", 'Ž': "Z",
+}
+
+// asciiFold transliterates s to ASCII. Characters without a Latin
+// equivalent become '?'.
+func asciiFold(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		switch {
+		case r < unicode.MaxASCII:
+			b.WriteRune(r)
+		case asciiFoldMap[r] != "":
+			b.WriteString(asciiFoldMap[r])
+		case unicode.IsSpace(r):
+			b.WriteByte(' ')
+		default:
+			b.WriteByte('?')
+		}
+	}
+	return b.String()
+}
+
+func truncateRunes(s string, n int) string {
+	if r := []rune(s); len(r) > n {
+		return string(r[:n])
+	}
+	return s
+}
+
+// truncateBytes cuts s to at most n bytes without splitting a UTF-8 sequence.
+func truncateBytes(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	for n > 0 && !isRuneStart(s[n]) {
+		n--
+	}
+	return s[:n]
+}
+
+func isRuneStart(b byte) bool { return b&0xC0 != 0x80 }
+
+// resolveWritebackDevices loads the devices a write-back targets.
+func resolveWritebackDevices(ctx context.Context, req *writebackRequest) ([]*storage.Device, []writebackResult, error) {
+	var devices []*storage.Device
+	var missing []writebackResult
+	seen := map[string]bool{}
+	if req.AllSaved {
+		saved := true
+		list, err := deviceStore.List(ctx, storage.DeviceFilter{IsSaved: &saved})
+		if err != nil {
+			return nil, nil, err
+		}
+		for _, d := range list {
+			if !seen[d.Serial] {
+				seen[d.Serial] = true
+				devices = append(devices, d)
+			}
+		}
+	}
+	for _, serial := range req.Serials {
+		serial = strings.TrimSpace(serial)
+		if serial == "" || seen[serial] {
+			continue
+		}
+		seen[serial] = true
+		d, err := deviceStore.Get(ctx, serial)
+		if err != nil {
+			missing = append(missing, writebackResult{Serial: serial, Error: "device not found"})
+			continue
+		}
+		devices = append(devices, d)
+	}
+	if len(devices) > writebackMaxDevices {
+		return nil, nil, fmt.Errorf("too many devices (%d, max %d)", len(devices), writebackMaxDevices)
+	}
+	return devices, missing, nil
+}
+
+// registerDeviceWritebackHandlers exposes the bulk write-back operation.
+func registerDeviceWritebackHandlers() {
+	// POST /api/devices/writeback - write asset/location info into devices
+	http.HandleFunc("/api/devices/writeback", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "POST only", http.StatusMethodNotAllowed)
+			return
+		}
+		var req writebackRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "bad json", http.StatusBadRequest)
+			return
+		}
+		if err := req.normalize(); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if deviceStore == nil {
+			http.Error(w, "device store unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		cfg, err := agent.GetSNMPConfig()
+		if err != nil {
+			http.Error(w, "snmp config: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(r.Context(), 5*time.Minute)
+		defer cancel()
+		devices, results, err := resolveWritebackDevices(ctx, &req)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		out := make([]writebackResult, len(devices))
+		sem := make(chan struct{}, writebackConcurrency)
+		var wg sync.WaitGroup
+		for i, d := range devices {
+			wg.Add(1)
+			sem <- struct{}{}
+			go func(i int, d *storage.Device) {
+				defer wg.Done()
+				defer func() { <-sem }()
+				out[i] = writebackDevice(ctx, cfg, &req, d)
+			}(i, d)
+		}
+		wg.Wait()
+		results = append(out, results...)
+
+		failed := 0
+		for _, res := range results {
+			if res.Error != "" {
+				failed++
+			}
+		}
+		if appLogger != nil && !req.DryRun {
+			username := ""
+			if p, ok := r.Context().Value(agentPrincipalContextKey).(*AgentPrincipal); ok && p != nil {
+				username = p.Username
+			}
+			appLogger.Info("Device write-back completed", "devices", len(results), "failed", failed,
+				"targets", strings.Join(req.Targets, ","), "by", username)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"dry_run": req.DryRun,
+			"total":   len(results),
+			"failed":  failed,
+			"results": results,
+		})
+	})
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"printmaster/agent/agent"
+	"printmaster/agent/scanscope"
+	"printmaster/agent/storage"
+
+	"github.com/gosnmp/gosnmp"
+)
+
+type fakeSetter struct {
+	pdus []gosnmp.SnmpPDU
+	resp gosnmp.SNMPError
+}
+
+func (f *fakeSetter) Set(pdus []gosnmp.SnmpPDU) (*gosnmp.SnmpPacket, error) {
+	f.pdus = append(f.pdus, pdus...)
+	return &gosnmp.SnmpPacket{Error: f.resp}, nil
+}
+
+func (f *fakeSetter) Close() error { return nil }
+
+func TestWritebackValues(t *testing.T) {
+	device := &storage.Device{}
+	device.Serial = "SN1"
+	device.AssetNumber = "AST-7"
+	device.Location = "Bâtiment B, 2ème étage \"Nord\""
+
+	req := &writebackRequest{Targets: []string{"location", "contact", "panel"}, Serials: []string{"SN1"}, PanelMaxLength: 24}
+	if err := req.normalize(); err != nil {
+		t.Fatalf("normalize: %v", err)
+	}
+	values := writebackValues(req, device)
+	if values["location"] != device.Location {
+		t.Errorf("location should keep UTF-8 by default, got %q", values["location"])
+	}
+	if values["contact"] != "AST-7" {
+		t.Errorf("contact = %q", values["contact"])
+	}
+	if got, want := values["panel"], "AST-7 Batiment B, 2eme e"; got != want {
+		t.Errorf("panel = %q, want %q", got, want)
+	}
+
+	req.ASCIIOnly = true
+	if got := writebackValues(req, device)["location"]; got != "Batiment B, 2eme etage \"Nord\"" {
+		t.Errorf("ascii location = %q", got)
+	}
+	if got := panelText("Büro \"A\"\t東京"); got != "Buro 'A'??" {
+		t.Errorf("panelText = %q", got)
+	}
+	if err := (&writebackRequest{Targets: []string{"hostname"}, AllSaved: true}).normalize(); err == nil {
+		t.Error("expected unknown target to be rejected")
+	}
+}
+
+func TestWritebackDevice(t *testing.T) {
+	setter := &fakeSetter{}
+	origSetter, origPJL := agent.NewSNMPSetter, sendPJL
+	defer func() { agent.NewSNMPSetter, sendPJL = origSetter, origPJL }()
+	var community string
+	agent.NewSNMPSetter = func(cfg *agent.SNMPConfig, target, writeCommunity string, timeoutSeconds int) (agent.SNMPSetter, error) {
+		community = writeCommunity
+		return setter, nil
+	}
+	var payload []byte
+	sendPJL = func(ctx context.Context, ip string, p []byte) error {
+		payload = p
+		return nil
+	}
+
+	device := &storage.Device{}
+	device.Serial, device.IP, device.AssetNumber = "SN1", "10.0.0.5", "AST-7"
+	cfg := &agent.SNMPConfig{Version: gosnmp.Version2c, Community: "public"}
+	req := &writebackRequest{Targets: []string{"location", "contact", "panel"}, Serials: []string{"SN1"}, WriteCommunity: "private"}
+	if err := req.normalize(); err != nil {
+		t.Fatalf("normalize: %v", err)
+	}
+
+	res := writebackDevice(context.Background(), cfg, req, device)
+	if res.Error != "" {
+		t.Fatalf("unexpected error: %s", res.Error)
+	}
+	if len(res.Skipped) != 1 || res.Skipped[0] != "location" {
+		t.Errorf("expected empty location skipped, got %v", res.Skipped)
+	}
+	if len(setter.pdus) != 1 || setter.pdus[0].Name != sysContactOID || setter.pdus[0].Value != "AST-7" {
+		t.Errorf("unexpected SET PDUs: %+v", setter.pdus)
+	}
+	if community != "private" {
+		t.Errorf("expected write community, got %q", community)
+	}
+	if !strings.Contains(string(payload), `@PJL RDYMSG DISPLAY = "AST-7"`) {
+		t.Errorf("unexpected PJL payload: %q", payload)
+	}
+
+	setter.resp = gosnmp.NoAccess
+	sendPJL = func(ctx context.Context, ip string, p []byte) error { return errors.New("connection refused") }
+	res = writebackDevice(context.Background(), cfg, req, device)
+	if !strings.Contains(res.Error, "snmp:") || !strings.Contains(res.Error, "pjl:") || len(res.Written) != 0 {
+		t.Errorf("expected both writes to fail, got %+v", res)
+	}
+
+	req.DryRun = true
+	setter.pdus = nil
+	res = writebackDevice(context.Background(), cfg, req, device)
+	if res.Error != "" || len(setter.pdus) != 0 || res.Values["contact"] != "AST-7" {
+		t.Errorf("dry run should only render values, got %+v", res)
+	}
+}
+
+func TestSendPJLRespectsScanScope(t *testing.T) {
+	scope, _ := scanscope.New("10.0.0.0/24")
+	scanscope.SetActive(scope)
+	var refused []string
+	scanscope.SetRefusalObserver(func(target, source string) { refused = append(refused, target+" "+source) })
+	t.Cleanup(func() {
+		scanscope.SetActive(nil)
+		scanscope.SetRefusalObserver(nil)
+	})
+
+	err := sendPJL(context.Background(), "127.0.0.1", pjlReadyMessage("AST-7"))
+	if !errors.Is(err, scanscope.ErrOutOfScope) {
+		t.Fatalf("sendPJL outside scope = %v, want ErrOutOfScope", err)
+	}
+	if len(refused) != 1 || refused[0] != "127.0.0.1 writeback" {
+		t.Fatalf("refusals = %v", refused)
+	}
+}
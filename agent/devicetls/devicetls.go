@@ -0,0 +1,266 @@
+// Package devicetls holds per-device TLS overrides for talking to printer web
+// UIs. Old firmware often only speaks TLS 1.0 or ciphers Go no longer offers
+// by default, and a few devices demand a client certificate; the proxy, the
+// web UI login adapters and the HTTP scrapers dial through this package so an
+// override set for one device applies everywhere, while every other device
+// keeps the secure defaults.
+//
+// Overrides are process-wide and keyed by device IP, like the scanner's port
+// overrides.
+package devicetls
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultMinVersion is the minimum TLS version used for devices without an
+// override.
+const DefaultMinVersion = tls.VersionTLS12
+
+var versions = map[string]uint16{
+	"1.0": tls.VersionTLS10,
+	"1.1": tls.VersionTLS11,
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+// Options is the TLS override for one device. The zero value means the
+// secure defaults.
+type Options struct {
+	// MinVersion is "1.0", "1.1", "1.2" or "1.3"; empty means 1.2.
+	MinVersion string `json:"min_version,omitempty"`
+	// LegacyCiphers also offers the CBC, RSA key exchange and 3DES suites
+	// that Go leaves out of its defaults.
+	LegacyCiphers bool `json:"legacy_ciphers,omitempty"`
+	// ClientCert and ClientKey are a PEM certificate and key presented to
+	// devices that require mutual TLS.
+	ClientCert string `json:"client_cert,omitempty"`
+	ClientKey  string `json:"client_key,omitempty"`
+}
+
+// IsZero reports whether o changes nothing.
+func (o Options) IsZero() bool {
+	return (o.MinVersion == "" || o.MinVersion == "1.2") && !o.LegacyCiphers && o.ClientCert == "" && o.ClientKey == ""
+}
+
+// HasClientCert reports whether a client certificate is configured.
+func (o Options) HasClientCert() bool {
+	return o.ClientCert != ""
+}
+
+// Validate checks the version and that the client certificate and key parse
+// and belong together.
+func (o Options) Validate() error {
+	if o.MinVersion != "" {
+		if _, ok := versions[o.MinVersion]; !ok {
+			return fmt.Errorf("invalid min_version %q (use 1.0, 1.1, 1.2 or 1.3)", o.MinVersion)
+		}
+	}
+	if (o.ClientCert == "") != (o.ClientKey == "") {
+		return errors.New("client certificate and key must be set together")
+	}
+	if o.ClientCert != "" {
+		if _, err := tls.X509KeyPair([]byte(o.ClientCert), []byte(o.ClientKey)); err != nil {
+			return fmt.Errorf("invalid client certificate: %w", err)
+		}
+	}
+	return nil
+}
+
+// Warnings describes how o weakens the connection, for the UI and logs.
+func (o Options) Warnings() []string {
+	var out []string
+	switch o.MinVersion {
+	case "1.0", "1.1":
+		out = append(out, "TLS "+o.MinVersion+" is deprecated and has known weaknesses; allow it only for devices whose firmware cannot be updated")
+	}
+	if o.LegacyCiphers {
+		out = append(out, "legacy cipher suites (CBC, RSA key exchange, 3DES) lack forward secrecy and are vulnerable to known attacks")
+	}
+	return out
+}
+
+// Config returns the client TLS configuration for o. Certificates are not
+// verified: printer web UIs almost always use self-signed certificates.
+func (o Options) Config() (*tls.Config, error) {
+	if err := o.Validate(); err != nil {
+		return nil, err
+	}
+	cfg := &tls.Config{
+		// #nosec G402 -- device web UIs use self-signed certificates
+		InsecureSkipVerify: true,
+		MinVersion:         DefaultMinVersion,
+	}
+	if v, ok := versions[o.MinVersion]; ok {
+		cfg.MinVersion = v
+	}
+	if o.LegacyCiphers {
+		// An explicit list is the only way to offer the suites Go dropped
+		// from its defaults; TLS 1.3 suites are not configurable and stay on.
+		for _, s := range tls.CipherSuites() {
+			cfg.CipherSuites = append(cfg.CipherSuites, s.ID)
+		}
+		for _, s := range tls.InsecureCipherSuites() {
+			cfg.CipherSuites = append(cfg.CipherSuites, s.ID)
+		}
+	}
+	if o.ClientCert != "" {
+		cert, err := tls.X509KeyPair([]byte(o.ClientCert), []byte(o.ClientKey))
+		if err != nil {
+			return nil, err
+		}
+		cfg.Certificates = []tls.Certificate{cert}
+	}
+	return cfg, nil
+}
+
+type override struct {
+	opts Options
+	cfg  *tls.Config
+}
+
+var (
+	mu        sync.RWMutex
+	overrides = make(map[string]override)
+)
+
+// Load replaces all overrides, keyed by device IP. Invalid entries are
+// skipped and returned as errors.
+func Load(all map[string]Options) []error {
+	next := make(map[string]override, len(all))
+	var errs []error
+	for host, o := range all {
+		if host == "" || o.IsZero() {
+			continue
+		}
+		cfg, err := o.Config()
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", host, err))
+			continue
+		}
+		next[host] = override{opts: o, cfg: cfg}
+	}
+	mu.Lock()
+	overrides = next
+	mu.Unlock()
+	return errs
+}
+
+// Set sets or, when o is zero, clears the override for host. It reports
+// whether anything changed.
+func Set(host string, o Options) (bool, error) {
+	if o.IsZero() {
+		mu.Lock()
+		defer mu.Unlock()
+		_, ok := overrides[host]
+		delete(overrides, host)
+		return ok, nil
+	}
+	cfg, err := o.Config()
+	if err != nil {
+		return false, err
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	old, ok := overrides[host]
+	overrides[host] = override{opts: o, cfg: cfg}
+	return !ok || old.opts != o, nil
+}
+
+// For returns the override for host, if any.
+func For(host string) (Options, bool) {
+	mu.RLock()
+	defer mu.RUnlock()
+	ov, ok := overrides[host]
+	return ov.opts, ok
+}
+
+// All returns a copy of all overrides, keyed by host.
+func All() map[string]Options {
+	mu.RLock()
+	defer mu.RUnlock()
+	out := make(map[string]Options, len(overrides))
+	for host, ov := range overrides {
+		out[host] = ov.opts
+	}
+	return out
+}
+
+// ConfigFor returns the TLS configuration to use for host (an IP or name,
+// without port): its override, or the secure defaults.
+func ConfigFor(host string) *tls.Config {
+	mu.RLock()
+	ov, ok := overrides[host]
+	mu.RUnlock()
+	var cfg *tls.Config
+	if ok {
+		cfg = ov.cfg.Clone()
+	} else {
+		cfg, _ = Options{}.Config()
+	}
+	if net.ParseIP(host) == nil {
+		cfg.ServerName = host
+	}
+	return cfg
+}
+
+// Transport makes t choose the TLS configuration per device when dialing
+// HTTPS, replacing its TLSClientConfig. It returns t.
+func Transport(t *http.Transport) *http.Transport {
+	dial := t.DialContext
+	if dial == nil {
+		dial = (&net.Dialer{Timeout: 15 * time.Second, KeepAlive: 30 * time.Second}).DialContext
+	}
+	handshakeTimeout := t.TLSHandshakeTimeout
+	if handshakeTimeout <= 0 {
+		handshakeTimeout = 10 * time.Second
+	}
+	t.TLSClientConfig = nil
+	t.DialTLSContext = func(ctx context.Context, network, addr string) (net.Conn, error) {
+		host, _, err := net.SplitHostPort(addr)
+		if err != nil {
+			host = addr
+		}
+		raw, err := dial(ctx, network, addr)
+		if err != nil {
+			return nil, err
+		}
+		conn := tls.Client(raw, ConfigFor(host))
+		hctx, cancel := context.WithTimeout(ctx, handshakeTimeout)
+		defer cancel()
+		if err := conn.HandshakeContext(hctx); err != nil {
+			raw.Close()
+			return nil, err
+		}
+		return conn, nil
+	}
+	return t
+}
+
+// Hint returns advice for a TLS handshake failure that a per-device override
+// can fix, or "" when err does not look like one.
+func Hint(err error) string {
+	if err == nil {
+		return ""
+	}
+	msg := strings.ToLower(err.Error())
+	switch {
+	case strings.Contains(msg, "protocol version"),
+		strings.Contains(msg, "no cipher suite"),
+		strings.Contains(msg, "handshake failure"),
+		strings.Contains(msg, "insufficient security"):
+		return "the device may only support an old TLS version or cipher; set a per-device TLS override (minimum version or legacy ciphers)"
+	case strings.Contains(msg, "certificate required"),
+		strings.Contains(msg, "bad certificate"):
+		return "the device may require a client certificate; configure one in the device's TLS override"
+	}
+	return ""
+}
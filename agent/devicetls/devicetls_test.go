@@ -0,0 +1,137 @@
+package devicetls
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func legacyServer(t *testing.T, configure func(*tls.Config)) (*httptest.Server, string) {
+	t.Helper()
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	}))
+	srv.TLS = &tls.Config{MinVersion: tls.VersionTLS10, MaxVersion: tls.VersionTLS11}
+	if configure != nil {
+		configure(srv.TLS)
+	}
+	srv.StartTLS()
+	t.Cleanup(srv.Close)
+	host, _, _ := net.SplitHostPort(srv.Listener.Addr().String())
+	return srv, host
+}
+
+func get(url string) error {
+	client := &http.Client{Timeout: 5 * time.Second, Transport: Transport(&http.Transport{DisableKeepAlives: true})}
+	resp, err := client.Get(url)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+func TestTransportAppliesPerDeviceMinVersion(t *testing.T) {
+	t.Cleanup(func() { Load(nil) })
+	srv, host := legacyServer(t, nil)
+
+	err := get(srv.URL)
+	if err == nil {
+		t.Fatal("TLS 1.1 device should be refused with the default settings")
+	}
+	if Hint(err) == "" {
+		t.Errorf("expected a hint for %v", err)
+	}
+
+	if changed, err := Set(host, Options{MinVersion: "1.0"}); err != nil || !changed {
+		t.Fatalf("Set = %v, %v", changed, err)
+	}
+	if err := get(srv.URL); err != nil {
+		t.Fatalf("override not applied: %v", err)
+	}
+
+	if changed, _ := Set(host, Options{}); !changed {
+		t.Fatal("clearing the override should report a change")
+	}
+	if _, ok := For(host); ok {
+		t.Fatal("zero options should remove the override")
+	}
+}
+
+func TestTransportPresentsClientCertificate(t *testing.T) {
+	t.Cleanup(func() { Load(nil) })
+	srv, host := legacyServer(t, func(c *tls.Config) {
+		c.MinVersion, c.MaxVersion = tls.VersionTLS12, 0
+		c.ClientAuth = tls.RequireAnyClientCert
+	})
+
+	if err := get(srv.URL); err == nil {
+		t.Fatal("request without a client certificate should fail")
+	}
+
+	certPEM, keyPEM := selfSigned(t)
+	if errs := Load(map[string]Options{host: {ClientCert: certPEM, ClientKey: keyPEM}}); len(errs) > 0 {
+		t.Fatalf("Load: %v", errs)
+	}
+	if err := get(srv.URL); err != nil {
+		t.Fatalf("client certificate not presented: %v", err)
+	}
+}
+
+func TestOptionsValidateAndWarnings(t *testing.T) {
+	if err := (Options{MinVersion: "1.4"}).Validate(); err == nil {
+		t.Error("unknown version should be rejected")
+	}
+	if err := (Options{ClientCert: "x"}).Validate(); err == nil {
+		t.Error("certificate without key should be rejected")
+	}
+	if w := (Options{}).Warnings(); len(w) != 0 {
+		t.Errorf("defaults should not warn: %v", w)
+	}
+	w := Options{MinVersion: "1.0", LegacyCiphers: true}.Warnings()
+	if len(w) != 2 || !strings.Contains(w[0], "TLS 1.0") {
+		t.Errorf("unexpected warnings: %v", w)
+	}
+	if cfg, _ := (Options{LegacyCiphers: true}).Config(); len(cfg.CipherSuites) == 0 {
+		t.Error("legacy ciphers should produce an explicit suite list")
+	}
+	if cfg := ConfigFor("printer.local"); cfg.MinVersion != tls.VersionTLS12 || cfg.ServerName != "printer.local" {
+		t.Errorf("unexpected default config: min %x server name %q", cfg.MinVersion, cfg.ServerName)
+	}
+}
+
+func selfSigned(t *testing.T) (string, string) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "printmaster-agent"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})),
+		string(pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}))
+}
@@ -0,0 +1,204 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+
+	"printmaster/agent/agent"
+	"printmaster/agent/scanner/discovery"
+)
+
+// discoveryMethodSettingsKey stores per-method enabled flags and options for
+// the pluggable discovery methods, keyed by method name.
+const discoveryMethodSettingsKey = "discovery_method_settings"
+
+var (
+	discoveryListenersMu sync.Mutex
+	discoveryListeners   *discovery.Listeners
+	// liveDiscoveryFunc identifies and stores a candidate IP; wired to the
+	// live-discovery handler in main once the scanner is configured.
+	liveDiscoveryFunc func(ip, method string)
+)
+
+func loadDiscoveryMethodSettings() map[string]discovery.Settings {
+	settings := make(map[string]discovery.Settings)
+	if agentConfigStore != nil {
+		_ = agentConfigStore.GetConfigValue(discoveryMethodSettingsKey, &settings)
+	}
+	return settings
+}
+
+// handleDiscoveryMethodResult logs a candidate from a pluggable method and
+// hands it to the live-discovery path.
+func handleDiscoveryMethodResult(r discovery.Result) {
+	msg := "DISCOVERY " + strings.ToUpper(r.Method) + ": discovered " + r.IP
+	if len(r.Details) > 0 {
+		keys := make([]string, 0, len(r.Details))
+		for k := range r.Details {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		parts := make([]string, 0, len(keys))
+		for _, k := range keys {
+			parts = append(parts, k+"="+r.Details[k])
+		}
+		msg += " (" + strings.Join(parts, ", ") + ")"
+	}
+	agent.AppendScanEvent(msg)
+	if liveDiscoveryFunc != nil {
+		go liveDiscoveryFunc(r.IP, r.Method)
+	}
+}
+
+func logDiscoveryMethodError(method string, err error) {
+	if appLogger != nil {
+		appLogger.Warn("Discovery method failed", "method", method, "error", err)
+	}
+}
+
+// startDiscoveryMethodListeners starts the enabled passive methods.
+func startDiscoveryMethodListeners() {
+	discoveryListenersMu.Lock()
+	if discoveryListeners == nil {
+		discoveryListeners = discovery.NewListeners(handleDiscoveryMethodResult, logDiscoveryMethodError)
+	}
+	listeners := discoveryListeners
+	discoveryListenersMu.Unlock()
+	listeners.Apply(loadDiscoveryMethodSettings())
+}
+
+// startActiveDiscoveryMethods runs the enabled active methods alongside a
+// scan of ranges. The returned func waits for them to finish.
+func startActiveDiscoveryMethods(ctx context.Context, ranges []string) func() {
+	settings := loadDiscoveryMethodSettings()
+	enabled := false
+	for _, m := range discovery.Methods() {
+		if _, ok := m.(discovery.ActiveMethod); ok && settings[m.Name()].Enabled {
+			enabled = true
+			break
+		}
+	}
+	if !enabled {
+		return func() {}
+	}
+
+	var scope discovery.Scope
+	if len(ranges) > 0 {
+		if parsed, err := agent.ParseRangeText(strings.Join(ranges, "\n"), 4096); err == nil {
+			scope.Targets = filterScanTargets(parsed.IPs, "discovery_methods")
+		}
+	}
+	if subnets, err := agent.GetLocalSubnets(); err == nil {
+		scope.Subnets = subnets
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for method, err := range discovery.RunActive(ctx, settings, scope, handleDiscoveryMethodResult) {
+			logDiscoveryMethodError(method, err)
+		}
+	}()
+	return func() { <-done }
+}
+
+type discoveryMethodInfo struct {
+	Name        string                  `json:"name"`
+	Description string                  `json:"description"`
+	Kind        string                  `json:"kind"`
+	Schema      []discovery.ConfigField `json:"schema"`
+	Enabled     bool                    `json:"enabled"`
+	Options     map[string]string       `json:"options,omitempty"`
+	Running     bool                    `json:"running"`
+}
+
+func discoveryMethodKind(m discovery.Method) string {
+	_, active := m.(discovery.ActiveMethod)
+	_, passive := m.(discovery.PassiveMethod)
+	switch {
+	case active && passive:
+		return "active+passive"
+	case passive:
+		return "passive"
+	default:
+		return "active"
+	}
+}
+
+type discoveryMethodRequest struct {
+	Name    string            `json:"name"`
+	Enabled bool              `json:"enabled"`
+	Options map[string]string `json:"options"`
+}
+
+// registerDiscoveryMethodHandlers exposes the pluggable discovery methods.
+func registerDiscoveryMethodHandlers() {
+	// GET  /api/discovery/methods - list registered methods with schema and settings
+	// POST /api/discovery/methods - enable/disable a method and set its options
+	http.HandleFunc("/api/discovery/methods", func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+			settings := loadDiscoveryMethodSettings()
+			running := make(map[string]bool)
+			discoveryListenersMu.Lock()
+			if discoveryListeners != nil {
+				for _, name := range discoveryListeners.Running() {
+					running[name] = true
+				}
+			}
+			discoveryListenersMu.Unlock()
+			out := make([]discoveryMethodInfo, 0)
+			for _, m := range discovery.Methods() {
+				s := settings[m.Name()]
+				out = append(out, discoveryMethodInfo{
+					Name:        m.Name(),
+					Description: m.Description(),
+					Kind:        discoveryMethodKind(m),
+					Schema:      m.ConfigSchema(),
+					Enabled:     s.Enabled,
+					Options:     s.Options,
+					Running:     running[m.Name()],
+				})
+			}
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(out)
+		case http.MethodPost:
+			var req discoveryMethodRequest
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+				http.Error(w, "bad json", http.StatusBadRequest)
+				return
+			}
+			m, ok := discovery.Lookup(strings.TrimSpace(req.Name))
+			if !ok {
+				http.Error(w, "unknown discovery method", http.StatusNotFound)
+				return
+			}
+			if _, err := discovery.NewConfig(m, req.Options); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			if agentConfigStore == nil {
+				http.Error(w, "config store unavailable", http.StatusServiceUnavailable)
+				return
+			}
+			settings := loadDiscoveryMethodSettings()
+			settings[m.Name()] = discovery.Settings{Enabled: req.Enabled, Options: req.Options}
+			if err := agentConfigStore.SetConfigValue(discoveryMethodSettingsKey, settings); err != nil {
+				http.Error(w, "failed to save settings", http.StatusInternalServerError)
+				return
+			}
+			if appLogger != nil {
+				appLogger.Info("Discovery method updated", "method", m.Name(), "enabled", req.Enabled)
+			}
+			startDiscoveryMethodListeners()
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(map[string]interface{}{"name": m.Name(), "enabled": req.Enabled, "options": req.Options})
+		default:
+			http.Error(w, "GET or POST only", http.StatusMethodNotAllowed)
+		}
+	})
+}
@@ -0,0 +1,227 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"printmaster/agent/agent"
+	pmsettings "printmaster/common/settings"
+)
+
+// Discovery safety limits. Changes that newly exceed the safe limits are
+// refused unless the caller overrides them; discoveryMaxAddresses can never be
+// exceeded, so a mistyped /8 is rejected outright.
+const (
+	discoverySafeMaxAddresses = 4096
+	discoverySafeMaxDuration  = time.Hour
+	discoveryMaxAddresses     = 65536
+)
+
+// Full discovery probes each address on these many TCP ports with the
+// liveness timeout; unresponsive hosts cost the whole budget.
+const (
+	discoveryLivenessPorts   = 5
+	discoveryLivenessTimeout = 500 * time.Millisecond
+)
+
+// DiscoverySettingChange is one discovery setting that differs from the
+// saved value.
+type DiscoverySettingChange struct {
+	Setting string      `json:"setting"`
+	From    interface{} `json:"from"`
+	To      interface{} `json:"to"`
+}
+
+// DiscoverySimulation estimates what a discovery configuration would scan.
+type DiscoverySimulation struct {
+	Valid  bool               `json:"valid"`
+	Errors []agent.ParseError `json:"errors,omitempty"`
+	// Source is what a scan would cover: "ranges", "subnet" or "none"
+	Source                   string                   `json:"source"`
+	RangeAddresses           int                      `json:"range_addresses"`
+	Subnet                   string                   `json:"subnet,omitempty"`
+	SubnetAddresses          int                      `json:"subnet_addresses,omitempty"`
+	TotalAddresses           int                      `json:"total_addresses"`
+	PreviousTotalAddresses   int                      `json:"previous_total_addresses"`
+	Concurrency              int                      `json:"concurrency"`
+	EstimatedDurationSeconds int                      `json:"estimated_duration_seconds"`
+	MethodsEnabled           []string                 `json:"methods_enabled"`
+	MethodsDisabled          []string                 `json:"methods_disabled"`
+	Changes                  []DiscoverySettingChange `json:"changes"`
+	Violations               []string                 `json:"violations,omitempty"`
+	ExceedsLimits            bool                     `json:"exceeds_limits"`
+	// RequiresOverride is set when the change newly exceeds or widens a scan
+	// beyond the safe limits; saving it needs override_safety_limits
+	RequiresOverride bool `json:"requires_override"`
+	Limits           struct {
+		SafeMaxAddresses       int `json:"safe_max_addresses"`
+		SafeMaxDurationSeconds int `json:"safe_max_duration_seconds"`
+		MaxAddresses           int `json:"max_addresses"`
+	} `json:"limits"`
+}
+
+// discoveryScope is what one discovery configuration would scan.
+type discoveryScope struct {
+	source          string
+	rangeAddresses  int
+	subnet          string
+	subnetAddresses int
+	total           int
+	estimated       time.Duration
+	errors          []agent.ParseError
+	err             error
+}
+
+// evaluateDiscoveryScope mirrors /discover: saved ranges win when manual
+// ranges are enabled, otherwise the detected subnet is scanned when subnet
+// scanning is on.
+func evaluateDiscoveryScope(d pmsettings.DiscoverySettings, subnet *net.IPNet) discoveryScope {
+	var scope discoveryScope
+	if strings.TrimSpace(d.RangesText) != "" {
+		res, err := agent.ParseRangeText(d.RangesText, discoveryMaxAddresses)
+		if err != nil {
+			scope.err = err
+		} else {
+			scope.rangeAddresses = res.Count
+			scope.errors = res.Errors
+		}
+	}
+	if subnet != nil {
+		scope.subnet = subnet.String()
+		ones, bits := subnet.Mask.Size()
+		scope.subnetAddresses = 1 << min(bits-ones, 30)
+	}
+	switch {
+	case !d.IPScanningEnabled:
+		scope.source = "none"
+	case d.ManualRanges && scope.rangeAddresses > 0:
+		scope.source = "ranges"
+		scope.total = scope.rangeAddresses
+	case d.SubnetScan && scope.subnetAddresses > 0:
+		scope.source = "subnet"
+		scope.total = scope.subnetAddresses
+	default:
+		scope.source = "none"
+	}
+	concurrency := max(d.Concurrency, 1)
+	batches := int(math.Ceil(float64(scope.total) / float64(concurrency)))
+	scope.estimated = time.Duration(batches*discoveryLivenessPorts) * discoveryLivenessTimeout
+	return scope
+}
+
+func (s discoveryScope) exceedsLimits() bool {
+	return s.total > discoverySafeMaxAddresses || s.estimated > discoverySafeMaxDuration
+}
+
+// simulateDiscoverySettings compares proposed discovery settings with the
+// current ones and estimates the scan they would run.
+func simulateDiscoverySettings(current, proposed pmsettings.DiscoverySettings, subnet *net.IPNet) *DiscoverySimulation {
+	sim := &DiscoverySimulation{Concurrency: max(proposed.Concurrency, 1)}
+	sim.Limits.SafeMaxAddresses = discoverySafeMaxAddresses
+	sim.Limits.SafeMaxDurationSeconds = int(discoverySafeMaxDuration.Seconds())
+	sim.Limits.MaxAddresses = discoveryMaxAddresses
+
+	before := evaluateDiscoveryScope(current, subnet)
+	after := evaluateDiscoveryScope(proposed, subnet)
+	sim.Source = after.source
+	sim.RangeAddresses = after.rangeAddresses
+	sim.Subnet = after.subnet
+	sim.SubnetAddresses = after.subnetAddresses
+	sim.TotalAddresses = after.total
+	sim.PreviousTotalAddresses = before.total
+	sim.EstimatedDurationSeconds = int(math.Ceil(after.estimated.Seconds()))
+	sim.Errors = after.errors
+	sim.Valid = after.err == nil && len(after.errors) == 0
+	if after.err != nil {
+		sim.Violations = append(sim.Violations, after.err.Error())
+	}
+
+	if after.total > discoverySafeMaxAddresses {
+		sim.Violations = append(sim.Violations, fmt.Sprintf("%s would scan %d addresses (safe limit %d)",
+			after.source, after.total, discoverySafeMaxAddresses))
+	}
+	if after.estimated > discoverySafeMaxDuration {
+		sim.Violations = append(sim.Violations, fmt.Sprintf("a scan could take up to %s at concurrency %d (safe limit %s)",
+			after.estimated.Round(time.Minute), sim.Concurrency, discoverySafeMaxDuration))
+	}
+	sim.ExceedsLimits = after.exceedsLimits()
+	sim.RequiresOverride = sim.ExceedsLimits && (!before.exceedsLimits() || after.total > before.total)
+
+	sim.MethodsEnabled, sim.MethodsDisabled, sim.Changes = diffDiscoverySettings(current, proposed)
+	return sim
+}
+
+// diffDiscoverySettings lists the settings that change, with the toggles
+// that turn on or off. Ranges are summarized by address counts instead.
+func diffDiscoverySettings(current, proposed pmsettings.DiscoverySettings) (enabled, disabled []string, changes []DiscoverySettingChange) {
+	enabled, disabled, changes = []string{}, []string{}, []DiscoverySettingChange{}
+	from, to := structToMap(current), structToMap(proposed)
+	keys := make([]string, 0, len(to))
+	for key := range to {
+		if key != "ranges_text" && key != "detected_subnet" {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		if reflect.DeepEqual(from[key], to[key]) {
+			continue
+		}
+		changes = append(changes, DiscoverySettingChange{Setting: key, From: from[key], To: to[key]})
+		if on, ok := to[key].(bool); ok {
+			if on {
+				enabled = append(enabled, key)
+			} else {
+				disabled = append(disabled, key)
+			}
+		}
+	}
+	return enabled, disabled, changes
+}
+
+// detectedSubnet returns the subnet scanned when subnet scanning is on.
+func detectedSubnet() *net.IPNet {
+	ipnets, err := agent.GetLocalSubnets()
+	if err != nil || len(ipnets) == 0 {
+		return nil
+	}
+	return &ipnets[0]
+}
+
+// handleDiscoverySimulate estimates the impact of discovery settings before
+// they are saved:
+//
+//	POST /settings/discovery/simulate {"discovery": {...}}
+//
+// Fields left out keep their saved values.
+func handleDiscoverySimulate(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if agentConfigStore == nil {
+		http.Error(w, "config store unavailable", http.StatusInternalServerError)
+		return
+	}
+	var req struct {
+		Discovery map[string]interface{} `json:"discovery"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid json", http.StatusBadRequest)
+		return
+	}
+	current := loadUnifiedSettings(agentConfigStore)
+	proposed := current
+	mapIntoStruct(req.Discovery, &proposed.Discovery)
+	pmsettings.Sanitize(&proposed)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(simulateDiscoverySettings(current.Discovery, proposed.Discovery, detectedSubnet()))
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	pmsettings "printmaster/common/settings"
+)
+
+func TestSimulateDiscoverySettings(t *testing.T) {
+	current := pmsettings.DefaultSettings().Discovery
+	current.IPScanningEnabled = true
+	current.ManualRanges = true
+	current.SubnetScan = true
+	current.Concurrency = 50
+	current.RangesText = "192.168.1.0/24"
+	_, subnet, _ := net.ParseCIDR("10.20.0.0/16")
+
+	proposed := current
+	proposed.RangesText = "192.168.1.0/24\n192.168.2.0/24"
+	proposed.SNMPEnabled = !current.SNMPEnabled
+	sim := simulateDiscoverySettings(current, proposed, subnet)
+	if !sim.Valid || sim.Source != "ranges" || sim.TotalAddresses != 512 || sim.PreviousTotalAddresses != 256 {
+		t.Fatalf("unexpected simulation: %+v", sim)
+	}
+	if sim.RequiresOverride || sim.EstimatedDurationSeconds != 28 { // 11 batches x 5 ports x 500ms
+		t.Fatalf("expected a safe scan of ~28s, got %+v", sim)
+	}
+	if len(sim.Changes) != 1 || sim.Changes[0].Setting != "snmp_enabled" {
+		t.Fatalf("unexpected changes: %+v", sim.Changes)
+	}
+
+	// Falling back to a /16 detected subnet needs an override
+	proposed = current
+	proposed.ManualRanges = false
+	sim = simulateDiscoverySettings(current, proposed, subnet)
+	if sim.Source != "subnet" || sim.TotalAddresses != 65536 || !sim.RequiresOverride || len(sim.Violations) != 1 {
+		t.Fatalf("expected subnet scan to need an override: %+v", sim)
+	}
+	if len(sim.MethodsDisabled) != 1 || sim.MethodsDisabled[0] != "manual_ranges" {
+		t.Fatalf("unexpected disabled methods: %+v", sim.MethodsDisabled)
+	}
+
+	// Already over the limit: unrelated edits pass, widening does not
+	current.ManualRanges = false
+	proposed = current
+	proposed.MDNSEnabled = !current.MDNSEnabled
+	if sim := simulateDiscoverySettings(current, proposed, subnet); !sim.ExceedsLimits || sim.RequiresOverride {
+		t.Fatalf("unrelated change should not need an override: %+v", sim)
+	}
+
+	// A /8 is over the hard limit
+	proposed = current
+	proposed.ManualRanges = true
+	proposed.RangesText = "10.0.0.0/8"
+	if sim := simulateDiscoverySettings(current, proposed, nil); sim.Valid || len(sim.Violations) == 0 {
+		t.Fatalf("expected /8 to be invalid: %+v", sim)
+	}
+}
+
+func TestHandleDiscoverySimulate(t *testing.T) {
+	store := newFakeConfigStore()
+	prev := agentConfigStore
+	agentConfigStore = store
+	t.Cleanup(func() { agentConfigStore = prev })
+
+	body := `{"discovery": {"ip_scanning_enabled": true, "manual_ranges": true, "ranges_text": "172.16.0.0/20"}}`
+	req := httptest.NewRequest(http.MethodPost, "/settings/discovery/simulate", strings.NewReader(body))
+	rec := httptest.NewRecorder()
+	handleDiscoverySimulate(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", rec.Code, rec.Body.String())
+	}
+	for _, want := range []string{`"total_addresses":4096`, `"source":"ranges"`, `"requires_override":false`} {
+		if !strings.Contains(rec.Body.String(), want) {
+			t.Errorf("response missing %s: %s", want, rec.Body.String())
+		}
+	}
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"sync"
+
+	pmsettings "printmaster/common/settings"
+)
+
+// The settings the agent is actually running with, reported in heartbeats so
+// the server can spot drift from the assigned policy. Local edits to managed
+// sections still take effect at runtime until the policy is re-applied, so
+// this can differ from what loadUnifiedSettings returns.
+var (
+	effectiveSettingsMu sync.RWMutex
+	effectiveSettings   *pmsettings.Settings
+)
+
+// recordEffectiveSettings notes the settings just applied at runtime.
+func recordEffectiveSettings(cfg pmsettings.Settings) {
+	effectiveSettingsMu.Lock()
+	effectiveSettings = &cfg
+	effectiveSettingsMu.Unlock()
+}
+
+// noteLocalSettingsChange records settings saved from the local settings
+// page. Discovery, features and spooler are applied immediately; SNMP is read
+// from the store on use and keeps the managed values while a server snapshot
+// is active.
+func noteLocalSettingsChange(cfg pmsettings.Settings) {
+	effective := loadUnifiedSettings(agentConfigStore)
+	effective.Discovery = cfg.Discovery
+	effective.Features = cfg.Features
+	effective.Spooler = cfg.Spooler
+	recordEffectiveSettings(effective)
+}
+
+// reportedEffectiveSettings returns the settings to report to the server,
+// with agent-local sections reset since they are not part of policy.
+func reportedEffectiveSettings() *pmsettings.Settings {
+	effectiveSettingsMu.RLock()
+	recorded := effectiveSettings
+	effectiveSettingsMu.RUnlock()
+	var cfg pmsettings.Settings
+	if recorded != nil {
+		cfg = *recorded
+	} else if agentConfigStore != nil {
+		cfg = loadUnifiedSettings(agentConfigStore)
+	} else {
+		return nil
+	}
+	pmsettings.StripAgentLocalFields(&cfg)
+	return &cfg
+}
@@ -0,0 +1,184 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sort"
+	"time"
+
+	"printmaster/agent/plugins"
+	"printmaster/agent/storage"
+)
+
+// pluginFieldsKey lists the RawData keys last set by enrichment plugins, so
+// they are kept when a plugin fails and never shadow keys the agent sets.
+const pluginFieldsKey = "plugin_fields"
+
+// enrichmentPlugins runs the plugins from the config file; nil when disabled.
+var enrichmentPlugins *plugins.Runner
+
+// initEnrichmentPlugins loads the allowlisted plugins from the config file.
+func initEnrichmentPlugins(cfg PluginsConfig) {
+	enrichmentPlugins = nil
+	if !cfg.Enabled || len(cfg.Enrichment) == 0 {
+		return
+	}
+	runner, errs := plugins.New(cfg.runnerConfig())
+	for _, err := range errs {
+		if appLogger != nil {
+			appLogger.Warn("Enrichment plugin disabled", "error", err)
+		}
+	}
+	enrichmentPlugins = runner
+	if appLogger != nil {
+		for _, st := range runner.Statuses() {
+			appLogger.Info("Enrichment plugin loaded", "name", st.Name, "path", st.Path, "stages", st.Stages, "pinned", st.Pinned)
+		}
+	}
+}
+
+// pluginInput is the JSON document a plugin reads on stdin.
+type pluginInput struct {
+	Stage   plugins.Stage            `json:"stage"`
+	Device  *storage.Device          `json:"device"`
+	Metrics *storage.MetricsSnapshot `json:"metrics,omitempty"`
+}
+
+// enrichDevice runs the plugins for stage and merges their fields into
+// device.RawData. Plugin fields saved on existing are carried over first so a
+// plugin that fails or times out does not erase what it reported before.
+// Returns true when RawData changed.
+func enrichDevice(ctx context.Context, stage plugins.Stage, device, existing *storage.Device, metrics *storage.MetricsSnapshot) bool {
+	if device == nil {
+		return false
+	}
+	changed := false
+	if existing != nil && existing != device {
+		changed = carryPluginFields(device, existing)
+	}
+	if !enrichmentPlugins.Active(stage) {
+		return changed
+	}
+	fields, errs := enrichmentPlugins.Run(ctx, stage, pluginInput{Stage: stage, Device: device, Metrics: metrics})
+	for _, err := range errs {
+		if appLogger != nil {
+			appLogger.WarnRateLimited("plugin_"+device.Serial, 10*time.Minute, "Enrichment plugin failed",
+				"serial", device.Serial, "ip", device.IP, "stage", string(stage), "error", err)
+		}
+	}
+	return mergePluginFields(device, fields) || changed
+}
+
+// mergePluginFields sets plugin fields on device.RawData. Keys the agent set
+// itself are left alone.
+func mergePluginFields(device *storage.Device, fields map[string]interface{}) bool {
+	if len(fields) == 0 {
+		return false
+	}
+	if device.RawData == nil {
+		device.RawData = map[string]interface{}{}
+	}
+	owned := pluginOwnedFields(device.RawData)
+	changed := false
+	for k, v := range fields {
+		if k == pluginFieldsKey {
+			continue
+		}
+		if _, exists := device.RawData[k]; exists {
+			if _, mine := owned[k]; !mine {
+				if appLogger != nil {
+					appLogger.Debug("Enrichment plugin field ignored; key is set by the agent", "serial", device.Serial, "field", k)
+				}
+				continue
+			}
+		}
+		device.RawData[k] = v
+		owned[k] = struct{}{}
+		changed = true
+	}
+	setPluginOwnedFields(device.RawData, owned)
+	return changed
+}
+
+// carryPluginFields copies plugin fields from the stored device onto a fresh
+// one built from a new scan.
+func carryPluginFields(device, existing *storage.Device) bool {
+	if existing.RawData == nil {
+		return false
+	}
+	prev := pluginOwnedFields(existing.RawData)
+	if len(prev) == 0 {
+		return false
+	}
+	if device.RawData == nil {
+		device.RawData = map[string]interface{}{}
+	}
+	owned := pluginOwnedFields(device.RawData)
+	changed := false
+	for k := range prev {
+		v, ok := existing.RawData[k]
+		if !ok {
+			continue
+		}
+		if _, taken := device.RawData[k]; taken {
+			continue
+		}
+		device.RawData[k] = v
+		owned[k] = struct{}{}
+		changed = true
+	}
+	setPluginOwnedFields(device.RawData, owned)
+	return changed
+}
+
+func pluginOwnedFields(raw map[string]interface{}) map[string]struct{} {
+	owned := map[string]struct{}{}
+	switch v := raw[pluginFieldsKey].(type) {
+	case []string:
+		for _, k := range v {
+			owned[k] = struct{}{}
+		}
+	case []interface{}:
+		for _, k := range v {
+			if s, ok := k.(string); ok {
+				owned[s] = struct{}{}
+			}
+		}
+	}
+	return owned
+}
+
+func setPluginOwnedFields(raw map[string]interface{}, owned map[string]struct{}) {
+	if len(owned) == 0 {
+		return
+	}
+	keys := make([]string, 0, len(owned))
+	for k := range owned {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	raw[pluginFieldsKey] = keys
+}
+
+// registerPluginHandlers exposes the loaded plugins and their run counters.
+func registerPluginHandlers() {
+	// GET /api/plugins - configured enrichment plugins (admin only)
+	http.HandleFunc("/api/plugins", handlePlugins)
+}
+
+func handlePlugins(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "GET only", http.StatusMethodNotAllowed)
+		return
+	}
+	statuses := enrichmentPlugins.Statuses()
+	if statuses == nil {
+		statuses = []plugins.Status{}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"enabled": enrichmentPlugins != nil,
+		"plugins": statuses,
+	})
+}
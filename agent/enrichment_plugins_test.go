@@ -0,0 +1,69 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"printmaster/agent/plugins"
+	"printmaster/agent/storage"
+)
+
+func TestEnrichDeviceMergesAndCarriesPluginFields(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("shell script plugins")
+	}
+	dir := t.TempDir()
+	script := filepath.Join(dir, "cmdb.sh")
+	flag := filepath.Join(dir, "fail")
+	body := "#!/bin/sh\n[ -f " + flag + " ] && exit 1\necho '{\"cost_center\":\"CC-42\",\"model\":\"spoofed\"}'\n"
+	if err := os.WriteFile(script, []byte(body), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	initEnrichmentPlugins(PluginsConfig{Enabled: true, Enrichment: []EnrichmentPluginEntry{{Name: "cmdb", Path: script}}})
+	t.Cleanup(func() { enrichmentPlugins = nil })
+	if enrichmentPlugins == nil || !enrichmentPlugins.Active(plugins.StageDiscovery) {
+		t.Fatal("plugin not loaded")
+	}
+
+	device := &storage.Device{}
+	device.Serial = "JPBCD12345"
+	device.RawData = map[string]interface{}{"model": "from-snmp"}
+	if !enrichDevice(context.Background(), plugins.StageDiscovery, device, nil, nil) {
+		t.Fatal("expected RawData to change")
+	}
+	if device.RawData["cost_center"] != "CC-42" {
+		t.Fatalf("plugin field not merged: %v", device.RawData)
+	}
+	if device.RawData["model"] != "from-snmp" {
+		t.Fatal("plugin overwrote a key set by the agent")
+	}
+
+	// A failing plugin keeps the fields it reported on the previous scan
+	if err := os.WriteFile(flag, nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	rescanned := &storage.Device{}
+	rescanned.Serial = "JPBCD12345"
+	rescanned.RawData = map[string]interface{}{"model": "from-snmp"}
+	enrichDevice(context.Background(), plugins.StageDiscovery, rescanned, device, nil)
+	if rescanned.RawData["cost_center"] != "CC-42" {
+		t.Fatalf("plugin field lost on rescan: %v", rescanned.RawData)
+	}
+	if st := enrichmentPlugins.Statuses(); st[0].Failures != 1 || st[0].LastError == "" {
+		t.Fatalf("failure not recorded: %+v", st[0])
+	}
+}
+
+func TestInitEnrichmentPluginsDisabled(t *testing.T) {
+	initEnrichmentPlugins(PluginsConfig{Enabled: false, Enrichment: []EnrichmentPluginEntry{{Name: "x", Path: "/bin/true"}}})
+	if enrichmentPlugins != nil {
+		t.Fatal("disabled plugins should not load")
+	}
+	device := &storage.Device{}
+	if enrichDevice(context.Background(), plugins.StageDiscovery, device, nil, nil) || device.RawData != nil {
+		t.Fatal("no plugins should leave the device untouched")
+	}
+}
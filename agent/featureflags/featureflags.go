@@ -13,3 +13,55 @@ func SetEpsonRemoteMode(enabled bool) {
 func EpsonRemoteModeEnabled() bool {
 	return epsonRemoteMode.Load()
 }
+
+var statusPageOCR atomic.Bool
+
+// SetStatusPageOCR enables or disables the status page OCR counter fallback.
+func SetStatusPageOCR(enabled bool) {
+	statusPageOCR.Store(enabled)
+}
+
+// StatusPageOCREnabled reports whether status page OCR should be attempted.
+func StatusPageOCREnabled() bool {
+	return statusPageOCR.Load()
+}
+
+var unknownDeviceTelemetry atomic.Bool
+
+// SetUnknownDeviceTelemetry enables or disables uploading unknown-device samples.
+func SetUnknownDeviceTelemetry(enabled bool) {
+	unknownDeviceTelemetry.Store(enabled)
+}
+
+// UnknownDeviceTelemetryEnabled reports whether unknown-device samples are uploaded.
+func UnknownDeviceTelemetryEnabled() bool {
+	return unknownDeviceTelemetry.Load()
+}
+
+// Server entitlements. These default to enabled so standalone agents and
+// agents talking to older servers keep working; the server turns them off
+// through the feature flags in its managed settings snapshot.
+var (
+	remoteProxyDisabled     atomic.Bool
+	firmwareUpdatesDisabled atomic.Bool
+)
+
+// SetRemoteProxy enables or disables serving proxy requests from the server.
+func SetRemoteProxy(enabled bool) {
+	remoteProxyDisabled.Store(!enabled)
+}
+
+// RemoteProxyEnabled reports whether proxy requests from the server are allowed.
+func RemoteProxyEnabled() bool {
+	return !remoteProxyDisabled.Load()
+}
+
+// SetFirmwareUpdates enables or disables agent self-updates from the server.
+func SetFirmwareUpdates(enabled bool) {
+	firmwareUpdatesDisabled.Store(!enabled)
+}
+
+// FirmwareUpdatesEnabled reports whether agent self-updates may run.
+func FirmwareUpdatesEnabled() bool {
+	return !firmwareUpdatesDisabled.Load()
+}
@@ -35,3 +35,35 @@ func TestEpsonRemoteModeToggle(t *testing.T) {
 		}
 	}
 }
+
+func TestStatusPageOCR(t *testing.T) {
+	t.Parallel()
+
+	if StatusPageOCREnabled() {
+		t.Error("StatusPageOCREnabled() should default to false")
+	}
+	SetStatusPageOCR(true)
+	if !StatusPageOCREnabled() {
+		t.Error("StatusPageOCREnabled() should be true after SetStatusPageOCR(true)")
+	}
+	SetStatusPageOCR(false)
+	if StatusPageOCREnabled() {
+		t.Error("StatusPageOCREnabled() should be false after SetStatusPageOCR(false)")
+	}
+}
+
+func TestServerEntitlementsDefaultEnabled(t *testing.T) {
+	if !RemoteProxyEnabled() || !FirmwareUpdatesEnabled() {
+		t.Fatal("server entitlements should default to enabled")
+	}
+	SetRemoteProxy(false)
+	SetFirmwareUpdates(false)
+	if RemoteProxyEnabled() || FirmwareUpdatesEnabled() {
+		t.Fatal("entitlements should be disabled after clearing them")
+	}
+	SetRemoteProxy(true)
+	SetFirmwareUpdates(true)
+	if !RemoteProxyEnabled() || !FirmwareUpdatesEnabled() {
+		t.Fatal("entitlements should be enabled again")
+	}
+}
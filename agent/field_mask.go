@@ -0,0 +1,179 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+	"sort"
+	"strings"
+
+	"printmaster/agent/storage"
+)
+
+// fieldMask is the set of JSON fields requested with ?fields=serial,ip,...
+// so the UI and pollers can skip large fields such as raw_data. A nil mask
+// selects every field.
+type fieldMask map[string]bool
+
+// parseFieldMask reads ?fields= as a comma-separated list; the parameter may
+// also be repeated. Returns nil when no fields were given.
+func parseFieldMask(r *http.Request) fieldMask {
+	var mask fieldMask
+	for _, v := range r.URL.Query()["fields"] {
+		for _, f := range strings.Split(v, ",") {
+			if f = strings.ToLower(strings.TrimSpace(f)); f != "" {
+				if mask == nil {
+					mask = fieldMask{}
+				}
+				mask[f] = true
+			}
+		}
+	}
+	return mask
+}
+
+// has reports whether name is selected.
+func (m fieldMask) has(name string) bool {
+	return m == nil || m[name]
+}
+
+// any reports whether any of names is selected.
+func (m fieldMask) any(names ...string) bool {
+	if m == nil {
+		return true
+	}
+	for _, n := range names {
+		if m[n] {
+			return true
+		}
+	}
+	return false
+}
+
+// filter returns obj with only the selected keys.
+func (m fieldMask) filter(obj map[string]interface{}) map[string]interface{} {
+	if m == nil {
+		return obj
+	}
+	out := make(map[string]interface{}, len(m))
+	for k, v := range obj {
+		if m[k] {
+			out[k] = v
+		}
+	}
+	return out
+}
+
+// unknown returns the requested fields not accepted by known, sorted.
+func (m fieldMask) unknown(known func(string) bool) []string {
+	var bad []string
+	for f := range m {
+		if !known(f) {
+			bad = append(bad, f)
+		}
+	}
+	sort.Strings(bad)
+	return bad
+}
+
+// rejectUnknownFields answers 400 when the mask names fields the endpoint
+// does not have, so a typo does not silently return an empty object.
+func rejectUnknownFields(w http.ResponseWriter, m fieldMask, known func(string) bool) bool {
+	if bad := m.unknown(known); len(bad) > 0 {
+		http.Error(w, "unknown field(s): "+strings.Join(bad, ", "), http.StatusBadRequest)
+		return true
+	}
+	return false
+}
+
+// jsonFieldNames lists the JSON names of a struct's fields, including those
+// of embedded structs.
+func jsonFieldNames(t reflect.Type) map[string]bool {
+	names := map[string]bool{}
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag := f.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, _, _ := strings.Cut(tag, ",")
+		if f.Anonymous && name == "" {
+			for n := range jsonFieldNames(f.Type) {
+				names[n] = true
+			}
+			continue
+		}
+		if !f.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		names[name] = true
+	}
+	return names
+}
+
+// maskStruct encodes v and keeps only the selected fields. Values are left
+// as raw JSON so unselected fields are never decoded.
+func maskStruct(v interface{}, selected func(string) bool) (map[string]json.RawMessage, error) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var all map[string]json.RawMessage
+	if err := json.Unmarshal(b, &all); err != nil {
+		return nil, fmt.Errorf("mask fields: %w", err)
+	}
+	for k := range all {
+		if !selected(k) {
+			delete(all, k)
+		}
+	}
+	return all, nil
+}
+
+var (
+	profileDeviceFields  = jsonFieldNames(reflect.TypeOf(storage.Device{}))
+	profileMetricsFields = jsonFieldNames(reflect.TypeOf(storage.MetricsSnapshot{}))
+)
+
+// profileSelector applies a field mask to the two sections of
+// /api/devices/profile. A field may name a whole section ("device",
+// "latest_metrics"), be qualified ("latest_metrics.page_count") or be bare, in
+// which case it selects the field in every section that has it.
+type profileSelector struct {
+	mask fieldMask
+}
+
+func (p profileSelector) known(f string) bool {
+	if f == "device" || f == "latest_metrics" {
+		return true
+	}
+	if name, ok := strings.CutPrefix(f, "device."); ok {
+		return profileDeviceFields[name]
+	}
+	if name, ok := strings.CutPrefix(f, "latest_metrics."); ok {
+		return profileMetricsFields[name]
+	}
+	return profileDeviceFields[f] || profileMetricsFields[f]
+}
+
+// selects returns the field filter for a section, or nil if nothing in the
+// section was requested.
+func (p profileSelector) selects(section string, fields map[string]bool) func(string) bool {
+	if p.mask == nil || p.mask[section] {
+		return func(string) bool { return true }
+	}
+	sel := func(name string) bool { return p.mask[name] || p.mask[section+"."+name] }
+	for name := range fields {
+		if sel(name) {
+			return sel
+		}
+	}
+	return nil
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"printmaster/agent/storage"
+	commonstorage "printmaster/common/storage"
+)
+
+func TestParseFieldMask(t *testing.T) {
+	t.Parallel()
+	if m := parseFieldMask(httptest.NewRequest("GET", "/devices/get?serial=X", nil)); m != nil || !m.has("raw_data") {
+		t.Fatalf("no fields: mask = %v", m)
+	}
+	m := parseFieldMask(httptest.NewRequest("GET", "/devices/get?fields=serial,%20IP,,toner_levels&fields=model", nil))
+	want := fieldMask{"serial": true, "ip": true, "toner_levels": true, "model": true}
+	if !reflect.DeepEqual(m, want) {
+		t.Fatalf("mask = %v, want %v", m, want)
+	}
+	if m.has("raw_data") || !m.any("page_count", "toner_levels") {
+		t.Errorf("has/any mismatch for %v", m)
+	}
+	got := m.filter(map[string]interface{}{"serial": "X", "raw_data": map[string]interface{}{"big": 1}, "ip": "10.0.0.1"})
+	if len(got) != 2 || got["serial"] != "X" || got["ip"] != "10.0.0.1" {
+		t.Errorf("filter = %v", got)
+	}
+	if bad := m.unknown(func(f string) bool { return f != "model" && f != "ip" }); !reflect.DeepEqual(bad, []string{"ip", "model"}) {
+		t.Errorf("unknown = %v", bad)
+	}
+}
+
+func TestProfileSelector(t *testing.T) {
+	t.Parallel()
+	if !profileDeviceFields["serial"] || !profileDeviceFields["is_saved"] || !profileMetricsFields["page_count"] || !profileMetricsFields["fax_pages"] {
+		t.Fatalf("embedded struct fields missing: device=%v metrics=%v", profileDeviceFields, profileMetricsFields)
+	}
+
+	sel := profileSelector{mask: fieldMask{"serial": true, "ip": true, "latest_metrics.page_count": true}}
+	for f, want := range map[string]bool{"serial": true, "device.ip": true, "latest_metrics.page_count": true, "device": true, "nope": false, "device.page_count": false} {
+		if sel.known(f) != want {
+			t.Errorf("known(%q) = %v, want %v", f, !want, want)
+		}
+	}
+
+	dev := &storage.Device{Device: commonstorage.Device{Serial: "SN1", IP: "10.0.0.1", Model: "M404", RawData: map[string]interface{}{"big": "blob"}}}
+	deviceFields := sel.selects("device", profileDeviceFields)
+	if deviceFields == nil {
+		t.Fatal("device section should be selected")
+	}
+	out, err := maskStruct(dev, deviceFields)
+	if err != nil {
+		t.Fatalf("maskStruct: %v", err)
+	}
+	if len(out) != 2 || string(out["serial"]) != `"SN1"` || string(out["ip"]) != `"10.0.0.1"` {
+		t.Errorf("masked device = %s", out)
+	}
+
+	snap := &storage.MetricsSnapshot{MetricsSnapshot: commonstorage.MetricsSnapshot{Serial: "SN1", PageCount: 1200}}
+	metricsFields := sel.selects("latest_metrics", profileMetricsFields)
+	if metricsFields == nil {
+		t.Fatal("metrics section should be selected")
+	}
+	out, _ = maskStruct(snap, metricsFields)
+	if len(out) != 2 || string(out["page_count"]) != "1200" {
+		t.Errorf("masked metrics = %s", out)
+	}
+
+	// Sections with nothing requested are skipped; whole sections and no mask select all
+	if (profileSelector{mask: fieldMask{"model": true}}).selects("latest_metrics", profileMetricsFields) != nil {
+		t.Error("metrics section should be skipped when only device fields are requested")
+	}
+	if f := (profileSelector{mask: fieldMask{"latest_metrics": true}}).selects("latest_metrics", profileMetricsFields); f == nil || !f("anything") {
+		t.Error("whole section should select every field")
+	}
+	if f := (profileSelector{}).selects("device", profileDeviceFields); f == nil || !f("raw_data") {
+		t.Error("no mask should select every field")
+	}
+}
@@ -0,0 +1,572 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"printmaster/agent/storage"
+	commonutil "printmaster/common/util"
+)
+
+// Guest links
+//
+// A guest link is a signed, time-limited URL that grants read-only access to
+// the agent UI, e.g. for a vendor engineer during a support call. Admins
+// create and revoke links at runtime; every request made under a link is
+// written to a separate guest access log.
+//
+// The token is "pmg_<id>.<expiry>.<signature>" where the signature is an
+// HMAC-SHA256 of id and expiry with a per-agent secret. Only the link's
+// metadata is stored in the database; the secret lives in its own key file in
+// the data directory, so a leaked database does not leak usable tokens.
+
+const (
+	guestLinksKey = "guest_links"
+
+	guestTokenQueryParam = "guest_token"
+	guestTokenPrefix     = "pmg_"
+
+	guestLinkDefaultTTL = time.Hour
+	guestLinkMaxTTL     = 7 * 24 * time.Hour
+	guestLinkRetention  = 30 * 24 * time.Hour // Expired and revoked links stay listed this long
+
+	guestAuditFileName  = "guest_access.log"
+	guestSecretFileName = "guest_link.key"
+)
+
+var errGuestLinkNotFound = errors.New("guest link not found")
+
+// guestLink is the stored and API form of a guest link.
+type guestLink struct {
+	ID        string     `json:"id"`
+	Label     string     `json:"label"`
+	CreatedBy string     `json:"created_by"`
+	CreatedAt time.Time  `json:"created_at"`
+	ExpiresAt time.Time  `json:"expires_at"`
+	RevokedAt *time.Time `json:"revoked_at,omitempty"`
+	RevokedBy string     `json:"revoked_by,omitempty"`
+}
+
+// activeAt reports whether the link grants access at t.
+func (l *guestLink) activeAt(t time.Time) bool {
+	return l.RevokedAt == nil && t.Before(l.ExpiresAt)
+}
+
+// guestAuditEntry is one line of the guest access log.
+type guestAuditEntry struct {
+	Time       time.Time `json:"time"`
+	LinkID     string    `json:"link_id"`
+	Label      string    `json:"label"`
+	Method     string    `json:"method"`
+	Path       string    `json:"path"`
+	Status     int       `json:"status"`
+	RemoteAddr string    `json:"remote_addr"`
+	UserAgent  string    `json:"user_agent,omitempty"`
+}
+
+type guestLinkManager struct {
+	now func() time.Time
+
+	mu         sync.Mutex
+	secret     []byte
+	secretPath string // Empty keeps the secret in memory only
+	links      map[string]*guestLink
+	store      storage.AgentConfigStore
+	auditPath  string
+}
+
+var guestLinks = newGuestLinkManager()
+
+func newGuestLinkManager() *guestLinkManager {
+	return &guestLinkManager{
+		now:   time.Now,
+		links: make(map[string]*guestLink),
+	}
+}
+
+// initGuestLinks restores guest links and the signing secret kept in
+// dataDir, and sets where guest activity is logged. An empty dataDir (in-memory
+// storage) keeps the secret for this run only.
+func initGuestLinks(store storage.AgentConfigStore, dataDir, logDir string) {
+	secretPath := ""
+	if dataDir != "" {
+		secretPath = filepath.Join(dataDir, guestSecretFileName)
+	}
+	guestLinks.load(store, secretPath, filepath.Join(logDir, guestAuditFileName))
+}
+
+func (m *guestLinkManager) load(store storage.AgentConfigStore, secretPath, auditPath string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.store = store
+	m.secretPath = secretPath
+	m.auditPath = auditPath
+	if secretPath != "" {
+		if secret, err := os.ReadFile(secretPath); err == nil && len(secret) == sha256.Size {
+			m.secret = secret
+		}
+	}
+	if store == nil {
+		return
+	}
+	var saved []*guestLink
+	if err := store.GetConfigValue(guestLinksKey, &saved); err == nil {
+		for _, l := range saved {
+			if l != nil && l.ID != "" {
+				m.links[l.ID] = l
+			}
+		}
+	}
+}
+
+// ensureSecretLocked returns the signing secret, generating and saving one on
+// first use.
+func (m *guestLinkManager) ensureSecretLocked() ([]byte, error) {
+	if len(m.secret) > 0 {
+		return m.secret, nil
+	}
+	if m.secretPath != "" {
+		secret, err := commonutil.LoadOrCreateKey(m.secretPath)
+		if err != nil {
+			return nil, err
+		}
+		m.secret = secret
+		return secret, nil
+	}
+	secret := make([]byte, sha256.Size)
+	if _, err := rand.Read(secret); err != nil {
+		return nil, err
+	}
+	m.secret = secret
+	return secret, nil
+}
+
+// saveLocked prunes links that ended more than guestLinkRetention ago and
+// persists the rest.
+func (m *guestLinkManager) saveLocked() error {
+	cutoff := m.now().Add(-guestLinkRetention)
+	list := make([]*guestLink, 0, len(m.links))
+	for id, l := range m.links {
+		ended := l.ExpiresAt
+		if l.RevokedAt != nil && l.RevokedAt.Before(ended) {
+			ended = *l.RevokedAt
+		}
+		if ended.Before(cutoff) {
+			delete(m.links, id)
+			continue
+		}
+		list = append(list, l)
+	}
+	if m.store == nil {
+		return nil
+	}
+	return m.store.SetConfigValue(guestLinksKey, list)
+}
+
+func (m *guestLinkManager) sign(secret []byte, id string, expires int64) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(id + "." + strconv.FormatInt(expires, 10)))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// create issues a link valid for ttl and returns it with its token.
+func (m *guestLinkManager) create(label, createdBy string, ttl time.Duration) (*guestLink, string, error) {
+	if ttl <= 0 {
+		ttl = guestLinkDefaultTTL
+	}
+	if ttl > guestLinkMaxTTL {
+		return nil, "", errors.New("guest links may last at most " + guestLinkMaxTTL.String())
+	}
+	idBytes := make([]byte, 8)
+	if _, err := rand.Read(idBytes); err != nil {
+		return nil, "", err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	secret, err := m.ensureSecretLocked()
+	if err != nil {
+		return nil, "", err
+	}
+	now := m.now()
+	link := &guestLink{
+		ID:        hex.EncodeToString(idBytes),
+		Label:     strings.TrimSpace(label),
+		CreatedBy: createdBy,
+		CreatedAt: now.UTC(),
+		ExpiresAt: now.Add(ttl).UTC().Truncate(time.Second),
+	}
+	if link.Label == "" {
+		link.Label = link.ID
+	}
+	m.links[link.ID] = link
+	if err := m.saveLocked(); err != nil {
+		delete(m.links, link.ID)
+		return nil, "", err
+	}
+	expires := link.ExpiresAt.Unix()
+	token := guestTokenPrefix + link.ID + "." + strconv.FormatInt(expires, 10) + "." + m.sign(secret, link.ID, expires)
+	copied := *link
+	return &copied, token, nil
+}
+
+// revoke ends a link immediately. Revoking an ended link is a no-op.
+func (m *guestLinkManager) revoke(id, revokedBy string) (*guestLink, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	link, ok := m.links[id]
+	if !ok {
+		return nil, errGuestLinkNotFound
+	}
+	if link.RevokedAt == nil {
+		at := m.now().UTC()
+		link.RevokedAt = &at
+		link.RevokedBy = revokedBy
+		if err := m.saveLocked(); err != nil {
+			return nil, err
+		}
+	}
+	copied := *link
+	return &copied, nil
+}
+
+// list returns all retained links, newest first.
+func (m *guestLinkManager) list() []guestLink {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	list := make([]guestLink, 0, len(m.links))
+	for _, l := range m.links {
+		list = append(list, *l)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].CreatedAt.After(list[j].CreatedAt) })
+	return list
+}
+
+// active returns the link with id if it still grants access.
+func (m *guestLinkManager) active(id string) (*guestLink, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	link, ok := m.links[id]
+	if !ok || !link.activeAt(m.now()) {
+		return nil, false
+	}
+	copied := *link
+	return &copied, true
+}
+
+// verify checks a token's signature and that its link is still active.
+func (m *guestLinkManager) verify(token string) (*guestLink, bool) {
+	rest, ok := strings.CutPrefix(token, guestTokenPrefix)
+	if !ok {
+		return nil, false
+	}
+	parts := strings.Split(rest, ".")
+	if len(parts) != 3 {
+		return nil, false
+	}
+	expires, err := strconv.ParseInt(parts[1], 10, 64)
+	if err != nil {
+		return nil, false
+	}
+	m.mu.Lock()
+	secret := m.secret
+	m.mu.Unlock()
+	if len(secret) == 0 || !hmac.Equal([]byte(parts[2]), []byte(m.sign(secret, parts[0], expires))) {
+		return nil, false
+	}
+	link, ok := m.active(parts[0])
+	if !ok || link.ExpiresAt.Unix() != expires {
+		return nil, false
+	}
+	return link, true
+}
+
+// audit appends one request made under a guest link to the guest access log.
+func (m *guestLinkManager) audit(entry guestAuditEntry) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.auditPath == "" {
+		return
+	}
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return
+	}
+	if err := os.MkdirAll(filepath.Dir(m.auditPath), 0755); err != nil {
+		return
+	}
+	f, err := os.OpenFile(m.auditPath, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		if appLogger != nil {
+			appLogger.WarnRateLimited("guest_audit", 5*time.Minute, "Failed to write guest access log", "error", err)
+		}
+		return
+	}
+	defer f.Close()
+	f.Write(append(line, '\n'))
+}
+
+// auditEntries returns the latest guest access log entries, newest first,
+// optionally for one link.
+func (m *guestLinkManager) auditEntries(linkID string, limit int) ([]guestAuditEntry, error) {
+	m.mu.Lock()
+	path := m.auditPath
+	m.mu.Unlock()
+	entries := []guestAuditEntry{}
+	if path == "" {
+		return entries, nil
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return entries, nil
+		}
+		return nil, err
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var e guestAuditEntry
+		if json.Unmarshal(scanner.Bytes(), &e) != nil {
+			continue
+		}
+		if linkID == "" || e.LinkID == linkID {
+			entries = append(entries, e)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	for i, j := 0, len(entries)-1; i < j; i, j = i+1, j-1 {
+		entries[i], entries[j] = entries[j], entries[i]
+	}
+	if limit > 0 && len(entries) > limit {
+		entries = entries[:limit]
+	}
+	return entries, nil
+}
+
+// guestTokenFromRequest returns a guest token presented as a bearer token or
+// as the guest_token query parameter of the link.
+func guestTokenFromRequest(r *http.Request) string {
+	if auth := r.Header.Get("Authorization"); auth != "" {
+		if token, ok := strings.CutPrefix(auth, "Bearer "); ok && strings.HasPrefix(strings.TrimSpace(token), guestTokenPrefix) {
+			return strings.TrimSpace(token)
+		}
+	}
+	if r.URL != nil {
+		return strings.TrimSpace(r.URL.Query().Get(guestTokenQueryParam))
+	}
+	return ""
+}
+
+// guestPrincipal authenticates a guest token. Guests are viewers.
+func guestPrincipal(r *http.Request) (*AgentPrincipal, time.Time, bool) {
+	token := guestTokenFromRequest(r)
+	if token == "" {
+		return nil, time.Time{}, false
+	}
+	link, ok := guestLinks.verify(token)
+	if !ok {
+		if appLogger != nil {
+			appLogger.Debug("Invalid or ended guest link rejected", "path", r.URL.Path)
+		}
+		return nil, time.Time{}, false
+	}
+	return &AgentPrincipal{
+		Username:  "guest:" + link.Label,
+		Role:      agentRoleViewer,
+		Source:    "guest",
+		GuestLink: link.ID,
+	}, link.ExpiresAt, true
+}
+
+// isGuest reports whether the principal was issued for a guest link.
+func (p *AgentPrincipal) isGuest() bool {
+	return p != nil && p.Source == "guest"
+}
+
+// guestAllows reports whether a guest may serve r: guest links are read-only
+// even on routes a viewer could otherwise post to.
+func guestAllows(r *http.Request) bool {
+	return r.Method == http.MethodGet || r.Method == http.MethodHead
+}
+
+// serveGuest enforces a guest principal's link on every request, including
+// those on a session the link started, and logs the request to the guest
+// access log. next serves requests the link allows.
+func (a *agentAuthManager) serveGuest(w http.ResponseWriter, r *http.Request, principal *AgentPrincipal, next func(http.ResponseWriter)) {
+	lrw := &loggingResponseWriter{ResponseWriter: w, status: http.StatusOK}
+	defer func() {
+		guestLinks.audit(guestAuditEntry{
+			Time:       time.Now().UTC(),
+			LinkID:     principal.GuestLink,
+			Label:      strings.TrimPrefix(principal.Username, "guest:"),
+			Method:     r.Method,
+			Path:       r.URL.Path,
+			Status:     lrw.status,
+			RemoteAddr: r.RemoteAddr,
+			UserAgent:  r.UserAgent(),
+		})
+	}()
+
+	link, ok := guestLinks.active(principal.GuestLink)
+	if !ok {
+		// Revoked or expired: end the session the link started
+		if sess := a.sessionFromRequest(r); sess != nil {
+			a.sessions.Delete(sess.ID)
+			a.clearSessionCookie(lrw, r)
+		}
+		http.Error(lrw, "guest link expired or revoked", http.StatusUnauthorized)
+		return
+	}
+	if !guestAllows(r) {
+		http.Error(lrw, "forbidden: guest links are read-only", http.StatusForbidden)
+		return
+	}
+	if r.URL.Query().Get(guestTokenQueryParam) != "" && acceptsHTML(r) && a.sessionFromRequest(r) == nil {
+		// Keep the guest signed in for the page's own requests, never past
+		// the link's expiry
+		expiresAt := link.ExpiresAt
+		if time.Until(expiresAt) > defaultAgentSessionTTL {
+			expiresAt = time.Now().Add(defaultAgentSessionTTL)
+		}
+		a.issueSessionCookie(lrw, r, principal, "", expiresAt)
+	}
+	next(lrw)
+}
+
+// reportGuestLinkEvent records guest link changes in the server's audit log
+// when the agent is connected.
+func reportGuestLinkEvent(action string, link *guestLink, actor string) {
+	uploadWorkerMu.RLock()
+	worker := uploadWorker
+	uploadWorkerMu.RUnlock()
+	client := worker.Client()
+	if client == nil || link == nil {
+		return
+	}
+	details := map[string]interface{}{
+		"label":      link.Label,
+		"actor":      actor,
+		"expires_at": link.ExpiresAt,
+	}
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+		defer cancel()
+		if err := client.LogAuditEvent(ctx, "guest_link."+action, "guest_link", link.ID, details); err != nil && appLogger != nil {
+			appLogger.Warn("Failed to report guest link change", "link_id", link.ID, "error", err)
+		}
+	}()
+}
+
+// registerGuestLinkHandlers exposes guest link management to admins.
+func registerGuestLinkHandlers() {
+	// GET    /api/guest-links            - links, newest first
+	// POST   /api/guest-links            - {"label": "...", "ttl_minutes": 60}; returns the URL once
+	// DELETE /api/guest-links?id=        - revoke
+	http.HandleFunc("/api/guest-links", handleGuestLinks)
+	// GET /api/guest-links/audit?id=&limit= - guest access log, newest first
+	http.HandleFunc("/api/guest-links/audit", handleGuestLinkAudit)
+}
+
+func handleGuestLinks(w http.ResponseWriter, r *http.Request) {
+	actor := "system"
+	if p, ok := r.Context().Value(agentPrincipalContextKey).(*AgentPrincipal); ok && p != nil {
+		actor = p.Username
+	}
+	switch r.Method {
+	case http.MethodGet:
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(guestLinks.list())
+	case http.MethodPost:
+		var req struct {
+			Label      string `json:"label"`
+			TTLMinutes int    `json:"ttl_minutes"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "bad json", http.StatusBadRequest)
+			return
+		}
+		if req.TTLMinutes < 0 {
+			http.Error(w, "ttl_minutes must be positive", http.StatusBadRequest)
+			return
+		}
+		link, token, err := guestLinks.create(req.Label, actor, time.Duration(req.TTLMinutes)*time.Minute)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if appLogger != nil {
+			appLogger.Info("Guest link created", "link_id", link.ID, "label", link.Label, "by", actor, "expires_at", link.ExpiresAt)
+		}
+		reportGuestLinkEvent("created", link, actor)
+		scheme := "http"
+		if requestIsHTTPS(r) {
+			scheme = "https"
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"link":  link,
+			"token": token,
+			"url":   scheme + "://" + r.Host + "/?" + guestTokenQueryParam + "=" + token,
+		})
+	case http.MethodDelete:
+		link, err := guestLinks.revoke(strings.TrimSpace(r.URL.Query().Get("id")), actor)
+		if errors.Is(err, errGuestLinkNotFound) {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			http.Error(w, "failed to revoke guest link", http.StatusInternalServerError)
+			return
+		}
+		if appLogger != nil {
+			appLogger.Info("Guest link revoked", "link_id", link.ID, "label", link.Label, "by", actor)
+		}
+		reportGuestLinkEvent("revoked", link, actor)
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(link)
+	default:
+		http.Error(w, "GET, POST or DELETE only", http.StatusMethodNotAllowed)
+	}
+}
+
+func handleGuestLinkAudit(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "GET only", http.StatusMethodNotAllowed)
+		return
+	}
+	limit := 500
+	if v := r.URL.Query().Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > 5000 {
+			http.Error(w, "limit must be between 1 and 5000", http.StatusBadRequest)
+			return
+		}
+		limit = n
+	}
+	entries, err := guestLinks.auditEntries(strings.TrimSpace(r.URL.Query().Get("id")), limit)
+	if err != nil {
+		http.Error(w, "failed to read guest access log", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(entries)
+}
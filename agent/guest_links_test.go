@@ -0,0 +1,114 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestGuestLinkAccess(t *testing.T) {
+	prev := guestLinks
+	defer func() { guestLinks = prev }()
+	guestLinks = newGuestLinkManager()
+	store := newFakeConfigStore()
+	dir := t.TempDir()
+	secretPath := filepath.Join(dir, guestSecretFileName)
+	auditPath := filepath.Join(dir, guestAuditFileName)
+	guestLinks.load(store, secretPath, auditPath)
+
+	cfg := DefaultAgentConfig()
+	cfg.Web.Auth.AllowLocalAdmin = false
+	auth := newAgentAuthManager(cfg, newAgentSessionManager())
+	handler := auth.Wrap(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	serve := func(method, target, bearer string, cookie *http.Cookie) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, target, nil)
+		req.Header.Set("Accept", "text/html")
+		if bearer != "" {
+			req.Header.Set("Authorization", "Bearer "+bearer)
+		}
+		if cookie != nil {
+			req.AddCookie(cookie)
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	if _, _, err := guestLinks.create("too long", "admin", 8*24*time.Hour); err == nil {
+		t.Fatal("expected links past the maximum TTL to be rejected")
+	}
+	link, token, err := guestLinks.create("vendor call", "admin", 30*time.Minute)
+	if err != nil {
+		t.Fatalf("create: %v", err)
+	}
+
+	checks := []struct {
+		method, target, token string
+		want                  int
+	}{
+		{http.MethodGet, "/devices/list", token, http.StatusNoContent},
+		{http.MethodPost, "/discover", token, http.StatusForbidden}, // Read-only
+		{http.MethodGet, "/api/guest-links", token, http.StatusForbidden},
+		{http.MethodGet, "/devices/list", token[:len(token)-2] + "xx", http.StatusFound}, // Bad signature
+		{http.MethodGet, "/devices/list", strings.Replace(token, ".", ".9", 1), http.StatusFound},
+	}
+	for _, c := range checks {
+		if rec := serve(c.method, c.target, c.token, nil); rec.Code != c.want {
+			t.Errorf("%s %s: got %d, want %d", c.method, c.target, rec.Code, c.want)
+		}
+	}
+
+	// Opening the link signs the browser in until the link ends
+	rec := serve(http.MethodGet, "/?guest_token="+token, "", nil)
+	cookies := rec.Result().Cookies()
+	if rec.Code != http.StatusNoContent || len(cookies) != 1 || cookies[0].Name != agentSessionCookieName {
+		t.Fatalf("guest page: %d, cookies %v", rec.Code, cookies)
+	}
+	if rec := serve(http.MethodGet, "/devices/list", "", cookies[0]); rec.Code != http.StatusNoContent {
+		t.Fatalf("guest session: %d", rec.Code)
+	}
+
+	// Revoking ends the token and the session it started
+	if _, err := guestLinks.revoke(link.ID, "admin"); err != nil {
+		t.Fatalf("revoke: %v", err)
+	}
+	if rec := serve(http.MethodGet, "/devices/list", "", cookies[0]); rec.Code != http.StatusUnauthorized {
+		t.Fatalf("revoked guest session: %d", rec.Code)
+	}
+	if rec := serve(http.MethodGet, "/devices/list", token, nil); rec.Code != http.StatusFound {
+		t.Fatalf("revoked guest token: %d", rec.Code)
+	}
+
+	entries, err := guestLinks.auditEntries(link.ID, 0)
+	if err != nil {
+		t.Fatalf("auditEntries: %v", err)
+	}
+	if len(entries) != 6 || entries[0].Status != http.StatusUnauthorized || entries[len(entries)-1].Path != "/devices/list" {
+		t.Fatalf("unexpected guest audit: %+v", entries)
+	}
+
+	// Links and the signing secret survive a restart
+	reloaded := newGuestLinkManager()
+	reloaded.load(store, secretPath, auditPath)
+	if got := reloaded.list(); len(got) != 1 || got[0].RevokedAt == nil || got[0].RevokedBy != "admin" {
+		t.Fatalf("reloaded links: %+v", got)
+	}
+	if string(reloaded.secret) != string(guestLinks.secret) {
+		t.Fatal("signing secret not persisted")
+	}
+	// The secret is kept out of the database
+	for key := range store.values {
+		if key != guestLinksKey {
+			t.Fatalf("unexpected database value %q", key)
+		}
+	}
+	if secret, err := os.ReadFile(secretPath); err != nil || string(secret) != string(guestLinks.secret) {
+		t.Fatalf("signing secret not in its key file: %v", err)
+	}
+}
@@ -0,0 +1,279 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"printmaster/agent/storage"
+)
+
+// ipConflictsKey stores open duplicate-IP conflicts so automatic IP updates
+// stay suspended across restarts.
+const ipConflictsKey = "ip_conflicts"
+
+// ipConflictWindow is how close together two serials must answer at one IP
+// to count as a conflict rather than a readdressed device.
+const ipConflictWindow = time.Hour
+
+// ipConflict is an IP that answered with more than one serial. While it is
+// open, the devices involved keep their stored IP: discovery does not move
+// them to the conflicting address and relocation does not search for them.
+type ipConflict struct {
+	IP         string    `json:"ip"`
+	Serials    []string  `json:"serials"`
+	DetectedAt time.Time `json:"detected_at"`
+	LastSeen   time.Time `json:"last_seen"`
+}
+
+type ipSighting struct {
+	serial string
+	at     time.Time
+}
+
+// ipConflictTracker watches which serials answer at each IP. Two devices
+// answering for one address (a duplicate static IP, overlapping DHCP scopes)
+// make the stored device flip between serials on every scan.
+type ipConflictTracker struct {
+	window time.Duration
+	now    func() time.Time
+
+	mu        sync.Mutex
+	sightings map[string][]ipSighting // IP -> latest sighting per serial
+	conflicts map[string]*ipConflict  // IP -> open conflict
+	store     storage.AgentConfigStore
+}
+
+var ipConflicts = newIPConflictTracker()
+
+func newIPConflictTracker() *ipConflictTracker {
+	return &ipConflictTracker{
+		window:    ipConflictWindow,
+		now:       time.Now,
+		sightings: make(map[string][]ipSighting),
+		conflicts: make(map[string]*ipConflict),
+	}
+}
+
+// initIPConflicts restores open conflicts saved by a previous run.
+func initIPConflicts(store storage.AgentConfigStore) {
+	ipConflicts.load(store)
+}
+
+func (t *ipConflictTracker) load(store storage.AgentConfigStore) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.store = store
+	if store == nil {
+		return
+	}
+	var saved []*ipConflict
+	if err := store.GetConfigValue(ipConflictsKey, &saved); err != nil || len(saved) == 0 {
+		return
+	}
+	for _, c := range saved {
+		if c != nil && c.IP != "" {
+			t.conflicts[c.IP] = c
+		}
+	}
+	if appLogger != nil {
+		appLogger.Info("Loaded open IP conflicts", "count", len(t.conflicts))
+	}
+}
+
+// observe records that serial answered at ip. It returns the open conflict
+// for ip, if any, and whether this sighting opened it or added a serial.
+func (t *ipConflictTracker) observe(ip, serial string) (ipConflict, bool) {
+	if ip == "" || serial == "" {
+		return ipConflict{}, false
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	now := t.now()
+	kept := []ipSighting{{serial: serial, at: now}}
+	for _, s := range t.sightings[ip] {
+		if !strings.EqualFold(s.serial, serial) && now.Sub(s.at) < t.window {
+			kept = append(kept, s)
+		}
+	}
+	t.sightings[ip] = kept
+
+	c := t.conflicts[ip]
+	if c == nil && len(kept) < 2 {
+		return ipConflict{}, false
+	}
+	changed := false
+	if c == nil {
+		c = &ipConflict{IP: ip, DetectedAt: now}
+		t.conflicts[ip] = c
+	}
+	for _, s := range kept {
+		if !containsFold(c.Serials, s.serial) {
+			c.Serials = append(c.Serials, s.serial)
+			changed = true
+		}
+	}
+	sort.Strings(c.Serials)
+	c.LastSeen = now
+	if changed {
+		t.saveLocked()
+	}
+	return cloneIPConflict(c), changed
+}
+
+// suspended reports whether automatic IP updates are held for serial.
+func (t *ipConflictTracker) suspended(serial string) bool {
+	if serial == "" {
+		return false
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for _, c := range t.conflicts {
+		if containsFold(c.Serials, serial) {
+			return true
+		}
+	}
+	return false
+}
+
+// list returns the open conflicts ordered by IP.
+func (t *ipConflictTracker) list() []ipConflict {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	out := make([]ipConflict, 0, len(t.conflicts))
+	for _, c := range t.conflicts {
+		out = append(out, cloneIPConflict(c))
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].IP < out[j].IP })
+	return out
+}
+
+// resolve closes the conflict for ip and forgets its sightings, so detection
+// starts over. Returns false if there was no open conflict.
+func (t *ipConflictTracker) resolve(ip string) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if _, ok := t.conflicts[ip]; !ok {
+		return false
+	}
+	delete(t.conflicts, ip)
+	delete(t.sightings, ip)
+	t.saveLocked()
+	return true
+}
+
+func (t *ipConflictTracker) saveLocked() {
+	if t.store == nil {
+		return
+	}
+	open := make([]*ipConflict, 0, len(t.conflicts))
+	for _, c := range t.conflicts {
+		open = append(open, c)
+	}
+	if err := t.store.SetConfigValue(ipConflictsKey, open); err != nil && appLogger != nil {
+		appLogger.Warn("Failed to save IP conflicts", "error", err)
+	}
+}
+
+func cloneIPConflict(c *ipConflict) ipConflict {
+	out := *c
+	out.Serials = append([]string(nil), c.Serials...)
+	return out
+}
+
+func containsFold(list []string, s string) bool {
+	for _, v := range list {
+		if strings.EqualFold(v, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// checkIPConflict records a discovery answer and reports new conflicts. When
+// the device is part of an open conflict, device keeps the IP (and web UI
+// URL) it had before rather than taking the contested address.
+func checkIPConflict(existing, device *storage.Device) {
+	if device == nil || device.Serial == "" || device.IP == "" || device.IsUSB {
+		return
+	}
+	if c, changed := ipConflicts.observe(device.IP, device.Serial); changed {
+		reportIPConflict(c)
+	}
+	if existing == nil || existing.IP == "" || existing.IP == device.IP || !ipConflicts.suspended(device.Serial) {
+		return
+	}
+	if appLogger != nil {
+		appLogger.WarnRateLimited("ip_conflict_hold_"+device.Serial, 10*time.Minute,
+			"IP conflict: keeping stored IP for device", "serial", device.Serial, "ip", existing.IP, "answered_at", device.IP)
+	}
+	device.WebUIURL = replaceURLHost(device.WebUIURL, device.IP, existing.IP)
+	device.IP = existing.IP
+}
+
+// reportIPConflict logs a new or grown conflict and pushes it to the UI.
+func reportIPConflict(c ipConflict) {
+	if appLogger != nil {
+		appLogger.Warn("IP conflict: several devices answer at one address; automatic IP updates suspended",
+			"ip", c.IP, "serials", strings.Join(c.Serials, ","))
+	}
+	if sseHub != nil {
+		sseHub.Broadcast(SSEEvent{
+			Type: "ip_conflict",
+			Data: map[string]interface{}{
+				"ip":          c.IP,
+				"serials":     c.Serials,
+				"detected_at": c.DetectedAt,
+			},
+		})
+	}
+}
+
+// registerIPConflictHandlers exposes open conflicts and their resolution.
+func registerIPConflictHandlers() {
+	// GET  /api/devices/ip-conflicts - open conflicts
+	// POST /api/devices/ip-conflicts/resolve - {"ip": "..."} close a conflict
+	http.HandleFunc("/api/devices/ip-conflicts", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "GET only", http.StatusMethodNotAllowed)
+			return
+		}
+		conflicts := ipConflicts.list()
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"conflicts": conflicts,
+			"count":     len(conflicts),
+		})
+	})
+
+	http.HandleFunc("/api/devices/ip-conflicts/resolve", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "POST only", http.StatusMethodNotAllowed)
+			return
+		}
+		var req struct {
+			IP string `json:"ip"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || strings.TrimSpace(req.IP) == "" {
+			http.Error(w, "ip required", http.StatusBadRequest)
+			return
+		}
+		ip := strings.TrimSpace(req.IP)
+		if !ipConflicts.resolve(ip) {
+			http.Error(w, "no open conflict for "+ip, http.StatusNotFound)
+			return
+		}
+		if appLogger != nil {
+			appLogger.Info("IP conflict resolved", "ip", ip)
+		}
+		if sseHub != nil {
+			sseHub.Broadcast(SSEEvent{Type: "ip_conflict_resolved", Data: map[string]interface{}{"ip": ip}})
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{"success": true, "ip": ip})
+	})
+}
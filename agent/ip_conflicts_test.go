@@ -0,0 +1,106 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"printmaster/agent/storage"
+)
+
+func TestIPConflictTrackerDetectsAndResolves(t *testing.T) {
+	t.Parallel()
+	store := newFakeConfigStore()
+	tr := newIPConflictTracker()
+	tr.load(store)
+	now := time.Date(2026, 10, 18, 9, 0, 0, 0, time.UTC)
+	tr.now = func() time.Time { return now }
+
+	if _, changed := tr.observe("10.0.0.5", "SER-A"); changed {
+		t.Fatal("a single serial is not a conflict")
+	}
+	// The same device answering again is not a conflict either
+	if _, changed := tr.observe("10.0.0.5", "ser-a"); changed {
+		t.Fatal("serials differing only in case are the same device")
+	}
+
+	// Readdressed after the window: a different device now owns the IP
+	now = now.Add(2 * time.Hour)
+	if _, changed := tr.observe("10.0.0.5", "SER-B"); changed {
+		t.Fatal("sightings outside the window are not a conflict")
+	}
+
+	now = now.Add(5 * time.Minute)
+	c, changed := tr.observe("10.0.0.5", "SER-C")
+	if !changed || c.IP != "10.0.0.5" || len(c.Serials) != 2 || c.Serials[0] != "SER-B" || c.Serials[1] != "SER-C" {
+		t.Fatalf("conflict = %+v, changed=%v", c, changed)
+	}
+	if _, changed := tr.observe("10.0.0.5", "SER-B"); changed {
+		t.Error("a known serial answering again should not report the conflict again")
+	}
+	if !tr.suspended("ser-b") || !tr.suspended("SER-C") || tr.suspended("SER-A") {
+		t.Error("suspension should cover exactly the conflicting serials")
+	}
+
+	// Open conflicts survive a restart
+	restarted := newIPConflictTracker()
+	restarted.load(store)
+	if got := restarted.list(); len(got) != 1 || got[0].IP != "10.0.0.5" || len(got[0].Serials) != 2 {
+		t.Fatalf("restored conflicts = %+v", got)
+	}
+
+	if !tr.resolve("10.0.0.5") || tr.resolve("10.0.0.5") {
+		t.Error("resolve should close the conflict once")
+	}
+	if tr.suspended("SER-B") || len(tr.list()) != 0 {
+		t.Error("resolved conflict still suspends updates")
+	}
+	// Sightings were forgotten, so one more answer does not reopen it
+	if _, changed := tr.observe("10.0.0.5", "SER-B"); changed {
+		t.Error("resolution should reset detection")
+	}
+}
+
+func TestCheckIPConflictKeepsStoredIP(t *testing.T) {
+	prev := ipConflicts
+	ipConflicts = newIPConflictTracker()
+	defer func() { ipConflicts = prev }()
+
+	device := func(serial, ip string) *storage.Device {
+		d := &storage.Device{}
+		d.Serial, d.IP, d.WebUIURL = serial, ip, "http://"+ip+"/"
+		return d
+	}
+
+	// SER-B owns 10.0.0.9; SER-A, normally at 10.0.0.7, starts answering there too
+	checkIPConflict(device("SER-B", "10.0.0.9"), device("SER-B", "10.0.0.9"))
+	incoming := device("SER-A", "10.0.0.9")
+	checkIPConflict(device("SER-A", "10.0.0.7"), incoming)
+	if incoming.IP != "10.0.0.7" || incoming.WebUIURL != "http://10.0.0.7/" {
+		t.Fatalf("device moved to the contested IP: ip=%s url=%s", incoming.IP, incoming.WebUIURL)
+	}
+
+	// Devices outside the conflict still follow their new address
+	moved := device("SER-Z", "10.0.0.20")
+	checkIPConflict(device("SER-Z", "10.0.0.21"), moved)
+	if moved.IP != "10.0.0.20" {
+		t.Errorf("unrelated device IP = %s, want 10.0.0.20", moved.IP)
+	}
+
+	// Relocation is skipped for conflicting devices
+	r := newTestRelocator(map[string]string{"10.0.0.40": "SER-A"})
+	stranded := device("SER-A", "10.0.0.7")
+	stranded.MACAddress = "00:11:22:aa:bb:cc"
+	if r.Relocate(t.Context(), nil, stranded) {
+		t.Error("relocated a device with an open IP conflict")
+	}
+
+	ipConflicts.resolve("10.0.0.9")
+	if !newTestRelocator(map[string]string{"10.0.0.40": "SER-A"}).Relocate(t.Context(), nil, stranded) {
+		t.Error("relocation should resume once the conflict is resolved")
+	}
+	incoming = device("SER-A", "10.0.0.9")
+	checkIPConflict(device("SER-A", "10.0.0.7"), incoming)
+	if incoming.IP != "10.0.0.9" {
+		t.Errorf("after resolve ip = %s, want 10.0.0.9", incoming.IP)
+	}
+}
@@ -229,6 +229,13 @@ func collectMetricsAsync(jobID, serial, ip string, device *storage.Device) {
 	sendJobProgress(jobID, jobType, JobStatusRunning, 30, "Querying device metrics...", "", nil)
 
 	agentSnapshot, err := CollectMetrics(metricsCtx, ip, serial, vendorHint, 10)
+	if err != nil && device != nil && device.IP == ip {
+		sendJobProgress(jobID, jobType, JobStatusRunning, 40, "Device not responding, checking whether its IP changed...", "", nil)
+		if relocator.Relocate(metricsCtx, deviceStore, device) {
+			ip = device.IP
+			agentSnapshot, err = CollectMetrics(metricsCtx, ip, serial, vendorHint, 10)
+		}
+	}
 	if err != nil {
 		appLogger.Warn("Metrics collection failed", "serial", serial, "ip", ip, "error", err.Error())
 		if agent.DebugEnabled {
@@ -259,6 +266,8 @@ func collectMetricsAsync(jobID, serial, ip string, device *storage.Device) {
 	storageSnapshot.ScanToHostFlatbed = agentSnapshot.ScanToHostFlatbed
 	storageSnapshot.ScanToHostADF = agentSnapshot.ScanToHostADF
 	storageSnapshot.DuplexSheets = agentSnapshot.DuplexSheets
+	storageSnapshot.SimplexSheets = agentSnapshot.SimplexSheets
+	storageSnapshot.NUpSheets = agentSnapshot.NUpSheets
 	storageSnapshot.JamEvents = agentSnapshot.JamEvents
 	storageSnapshot.ScannerJamEvents = agentSnapshot.ScannerJamEvents
 
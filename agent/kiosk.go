@@ -0,0 +1,202 @@
+package main
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Kiosk tokens
+//
+// Kiosk tokens ([[web.auth.kiosk_tokens]]) let wall-mounted dashboards embed
+// the agent UI without an account. A kiosk principal is read-only and, beyond
+// the viewer role, limited to the routes of its scopes: no settings, logs,
+// device web UI proxy or anything that writes.
+
+const (
+	kioskScopeDevices = "devices" // Device list and details, status summary
+	kioskScopeMetrics = "metrics" // Page-count metrics, usage, sparklines and the Prometheus endpoint
+	kioskScopeEvents  = "events"  // SSE subscription (device and status events)
+
+	kioskTokenQueryParam = "kiosk_token"
+	kioskTokenPrefix     = "pmk_"
+)
+
+var kioskDefaultScopes = []string{kioskScopeDevices, kioskScopeMetrics, kioskScopeEvents}
+
+var kioskScopeRoutes = map[string][]string{
+	kioskScopeDevices: {"/", "/devices/list", "/devices/get", "/api/devices/profile", "/api/v1/status/summary"},
+	kioskScopeMetrics: {"/api/devices/metrics/latest", "/api/devices/metrics/history", "/api/devices/metrics/bounds", "/api/devices/usage", "/metrics/prometheus"},
+	kioskScopeEvents:  {"/events"},
+}
+
+var kioskScopePrefixes = map[string][]string{
+	kioskScopeMetrics: {"/api/v1/devices/"}, // {serial}/sparkline
+}
+
+// kioskSSEEvents are the event types streamed to kiosk subscribers; logs,
+// jobs and update progress stay with signed-in users.
+var kioskSSEEvents = map[string]struct{}{
+	"device_discovered": {},
+	"device_updated":    {},
+	"devices_merged":    {},
+	statusChangedEvent:  {},
+	snmpTrapEvent:       {},
+}
+
+type kioskToken struct {
+	name      string
+	scopes    []string
+	expiresAt time.Time // Zero = never
+}
+
+// parseKioskTokens indexes the configured kiosk tokens by hash, skipping
+// entries without a valid hash or expiry.
+func parseKioskTokens(entries []WebKioskToken) map[string]kioskToken {
+	tokens := make(map[string]kioskToken)
+	for _, entry := range entries {
+		name := strings.TrimSpace(entry.Name)
+		hash := strings.ToLower(strings.TrimSpace(entry.TokenHash))
+		if decoded, err := hex.DecodeString(hash); err != nil || len(decoded) != sha256.Size {
+			if appLogger != nil {
+				appLogger.Warn("Ignoring kiosk token with an invalid token_hash", "name", name)
+			}
+			continue
+		}
+		tok := kioskToken{name: name}
+		if raw := strings.TrimSpace(entry.ExpiresAt); raw != "" {
+			expires, err := parseKioskExpiry(raw)
+			if err != nil {
+				if appLogger != nil {
+					appLogger.Warn("Ignoring kiosk token with an invalid expires_at", "name", name, "expires_at", raw)
+				}
+				continue
+			}
+			tok.expiresAt = expires
+		}
+		for _, scope := range entry.Scopes {
+			scope = strings.ToLower(strings.TrimSpace(scope))
+			if _, ok := kioskScopeRoutes[scope]; ok && !slices.Contains(tok.scopes, scope) {
+				tok.scopes = append(tok.scopes, scope)
+			}
+		}
+		if len(entry.Scopes) == 0 {
+			tok.scopes = kioskDefaultScopes
+		}
+		if tok.name == "" {
+			tok.name = hash[:8]
+		}
+		tokens[hash] = tok
+	}
+	return tokens
+}
+
+// parseKioskExpiry accepts an RFC 3339 timestamp or a date, which expires at
+// the end of that day (UTC).
+func parseKioskExpiry(raw string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, raw); err == nil {
+		return t, nil
+	}
+	day, err := time.Parse("2006-01-02", raw)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return day.Add(24*time.Hour - time.Second), nil
+}
+
+// hashKioskToken returns the token_hash stored in the config for token.
+func hashKioskToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// newKioskToken generates a random kiosk token.
+func newKioskToken() (string, error) {
+	b := make([]byte, 24)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return kioskTokenPrefix + base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// kioskTokenFromRequest returns the kiosk token presented as a bearer token
+// or, for embedded pages and EventSource which cannot set headers, as the
+// kiosk_token query parameter.
+func kioskTokenFromRequest(r *http.Request) string {
+	if auth := r.Header.Get("Authorization"); auth != "" {
+		if token, ok := strings.CutPrefix(auth, "Bearer "); ok {
+			return strings.TrimSpace(token)
+		}
+	}
+	if r.URL != nil {
+		return strings.TrimSpace(r.URL.Query().Get(kioskTokenQueryParam))
+	}
+	return ""
+}
+
+// kioskPrincipal authenticates a kiosk token. It returns the principal and
+// the token expiry, or false for unknown and expired tokens.
+func (a *agentAuthManager) kioskPrincipal(r *http.Request) (*AgentPrincipal, time.Time, bool) {
+	if a == nil || len(a.kioskTokens) == 0 {
+		return nil, time.Time{}, false
+	}
+	token := kioskTokenFromRequest(r)
+	if token == "" {
+		return nil, time.Time{}, false
+	}
+	tok, ok := a.kioskTokens[hashKioskToken(token)]
+	if !ok {
+		return nil, time.Time{}, false
+	}
+	if !tok.expiresAt.IsZero() && time.Now().After(tok.expiresAt) {
+		if appLogger != nil {
+			appLogger.Debug("Expired kiosk token rejected", "name", tok.name, "path", r.URL.Path)
+		}
+		return nil, time.Time{}, false
+	}
+	return &AgentPrincipal{
+		Username: "kiosk:" + tok.name,
+		Role:     agentRoleViewer,
+		Source:   "kiosk",
+		Scopes:   tok.scopes,
+	}, tok.expiresAt, true
+}
+
+// isKiosk reports whether the principal was issued for a kiosk token.
+func (p *AgentPrincipal) isKiosk() bool {
+	return p != nil && p.Source == "kiosk"
+}
+
+// kioskAllows reports whether a kiosk principal may serve r: reads only, on
+// the routes of its scopes.
+func kioskAllows(p *AgentPrincipal, r *http.Request) bool {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		return false
+	}
+	path := r.URL.Path
+	for _, scope := range p.Scopes {
+		if slices.Contains(kioskScopeRoutes[scope], path) {
+			return true
+		}
+		for _, prefix := range kioskScopePrefixes[scope] {
+			if strings.HasPrefix(path, prefix) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// kioskReceivesEvent reports whether an SSE event goes to the subscriber.
+func kioskReceivesEvent(p *AgentPrincipal, eventType string) bool {
+	if !p.isKiosk() {
+		return true
+	}
+	_, ok := kioskSSEEvents[eventType]
+	return ok
+}
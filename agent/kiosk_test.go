@@ -0,0 +1,108 @@
+package main
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+	"time"
+)
+
+func TestKioskTokenAccess(t *testing.T) {
+	cfg := DefaultAgentConfig()
+	cfg.Web.Auth.AllowLocalAdmin = false
+	cfg.Web.Auth.KioskTokens = []WebKioskToken{
+		{Name: "lobby", TokenHash: hashKioskToken("pmk_lobby"), Scopes: []string{"devices", "events"}},
+		{Name: "old", TokenHash: hashKioskToken("pmk_old"), ExpiresAt: "2020-01-01"},
+		{Name: "broken", TokenHash: "not-a-hash"},
+	}
+	auth := newAgentAuthManager(cfg, newAgentSessionManager())
+	if len(auth.kioskTokens) != 2 {
+		t.Fatalf("expected 2 usable kiosk tokens, got %d", len(auth.kioskTokens))
+	}
+	handler := auth.Wrap(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	serve := func(method, target, bearer string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, target, nil)
+		if bearer != "" {
+			req.Header.Set("Authorization", "Bearer "+bearer)
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	checks := []struct {
+		method, target, token string
+		want                  int
+	}{
+		{http.MethodGet, "/devices/list", "pmk_lobby", http.StatusNoContent},
+		{http.MethodGet, "/events", "pmk_lobby", http.StatusNoContent},
+		{http.MethodGet, "/events?kiosk_token=pmk_lobby", "", http.StatusNoContent},
+		{http.MethodGet, "/api/devices/metrics/history", "pmk_lobby", http.StatusForbidden}, // No metrics scope
+		{http.MethodGet, "/settings", "pmk_lobby", http.StatusForbidden},
+		{http.MethodGet, "/proxy/CNB123/", "pmk_lobby", http.StatusForbidden},
+		{http.MethodPost, "/devices/get", "pmk_lobby", http.StatusForbidden},
+		{http.MethodGet, "/devices/list", "pmk_old", http.StatusUnauthorized},
+		{http.MethodGet, "/devices/list", "pmk_unknown", http.StatusUnauthorized},
+	}
+	for _, c := range checks {
+		if rec := serve(c.method, c.target, c.token); rec.Code != c.want {
+			t.Errorf("%s %s with %q: got %d, want %d", c.method, c.target, c.token, rec.Code, c.want)
+		}
+	}
+
+	// Opening the dashboard page with the token signs the browser in
+	req := httptest.NewRequest(http.MethodGet, "/?kiosk_token=pmk_lobby", nil)
+	req.Header.Set("Accept", "text/html")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	cookies := rec.Result().Cookies()
+	if rec.Code != http.StatusNoContent || len(cookies) != 1 || cookies[0].Name != agentSessionCookieName {
+		t.Fatalf("dashboard page: %d, cookies %v", rec.Code, cookies)
+	}
+	req = httptest.NewRequest(http.MethodGet, "/settings", nil)
+	req.AddCookie(cookies[0])
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if rec.Code != http.StatusForbidden {
+		t.Fatalf("kiosk session reached settings: %d", rec.Code)
+	}
+}
+
+func TestKioskReceivesEvent(t *testing.T) {
+	kiosk := &AgentPrincipal{Username: "kiosk:lobby", Role: agentRoleViewer, Source: "kiosk"}
+	if !kioskReceivesEvent(kiosk, "device_updated") || kioskReceivesEvent(kiosk, "log_entry") {
+		t.Fatal("unexpected kiosk event filter")
+	}
+	if !kioskReceivesEvent(nil, "log_entry") || !kioskReceivesEvent(&AgentPrincipal{Role: agentRoleViewer}, "log_entry") {
+		t.Fatal("signed-in users should receive every event")
+	}
+}
+
+func TestParseKioskExpiry(t *testing.T) {
+	got, err := parseKioskExpiry("2027-06-30")
+	if err != nil || !got.Equal(time.Date(2027, 6, 30, 23, 59, 59, 0, time.UTC)) {
+		t.Fatalf("date: %v %v", got, err)
+	}
+	if _, err := parseKioskExpiry("2027-06-30T12:00:00+02:00"); err != nil {
+		t.Fatalf("RFC 3339: %v", err)
+	}
+}
+
+func TestKioskTokenCommand(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	if code := runKioskTokenCommand([]string{"-name", "lobby", "-scopes", "devices,events", "-expires", "2027-06-30"}, &stdout, &stderr); code != cliExitOK {
+		t.Fatalf("exit %d: %s", code, stderr.String())
+	}
+	out := stdout.String()
+	token := regexp.MustCompile(`Token \(shown once\): (\S+)`).FindStringSubmatch(out)
+	if token == nil || !bytes.Contains(stdout.Bytes(), []byte(`token_hash = "`+hashKioskToken(token[1])+`"`)) {
+		t.Fatalf("unexpected output:\n%s", out)
+	}
+	if !bytes.Contains(stdout.Bytes(), []byte(`scopes = ["devices", "events"]`)) || !bytes.Contains(stdout.Bytes(), []byte(`expires_at = "2027-06-30"`)) {
+		t.Fatalf("unexpected output:\n%s", out)
+	}
+}
@@ -0,0 +1,327 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"printmaster/agent/agent"
+	"printmaster/agent/alerts"
+	"printmaster/agent/storage"
+)
+
+// localAlertSettingsKey stores the local alert rules and whether alerts are
+// forwarded to the server.
+const localAlertSettingsKey = "local_alert_settings"
+
+// localAlertSettings is the stored and API form of the alert configuration.
+type localAlertSettings struct {
+	Rules           []alerts.Rule `json:"rules"`
+	ForwardToServer bool          `json:"forward_to_server"`
+}
+
+var (
+	localAlertsMu     sync.Mutex
+	localAlertsConfig storage.AgentConfigStore
+	localAlertsStore  storage.AlertStore
+	localAlertsFwd    bool
+	localAlertEngine  = alerts.NewEngine(alerts.DefaultRules())
+)
+
+// initLocalAlerts loads the alert rules and the alerts left open by the last
+// run. Alerts are only stored when the device store supports them; the engine
+// runs either way so the UI still sees live alerts.
+func initLocalAlerts(config storage.AgentConfigStore, devices storage.DeviceStore) {
+	alertStore, _ := devices.(storage.AlertStore)
+	settings := localAlertSettings{Rules: alerts.DefaultRules()}
+	if config != nil {
+		var saved localAlertSettings
+		if err := config.GetConfigValue(localAlertSettingsKey, &saved); err == nil && saved.Rules != nil {
+			if err := alerts.ValidateRules(saved.Rules); err == nil {
+				settings = saved
+			} else if appLogger != nil {
+				appLogger.Warn("Ignoring saved alert rules", "error", err)
+			}
+		}
+	}
+
+	localAlertsMu.Lock()
+	localAlertsConfig = config
+	localAlertsStore = alertStore
+	localAlertsFwd = settings.ForwardToServer
+	localAlertsMu.Unlock()
+	localAlertEngine.SetRules(settings.Rules)
+
+	if alertStore == nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	open, err := alertStore.ListAlerts(ctx, storage.AlertFilter{OpenOnly: true})
+	if err != nil {
+		if appLogger != nil {
+			appLogger.Warn("Failed to load open alerts", "error", err)
+		}
+		return
+	}
+	restored := make([]alerts.Alert, 0, len(open))
+	for _, a := range open {
+		restored = append(restored, engineAlert(a))
+	}
+	localAlertEngine.Restore(restored)
+}
+
+// evaluateMetricsAlerts runs the alert rules against a freshly saved metrics
+// snapshot.
+func evaluateMetricsAlerts(device *storage.Device, snapshot *storage.MetricsSnapshot) {
+	if device == nil || snapshot == nil {
+		return
+	}
+	applyAlertResult(localAlertEngine.Observe(alerts.Sample{
+		Device:      alertDevice(device),
+		Timestamp:   snapshot.Timestamp,
+		TonerLevels: snapshot.TonerLevels,
+		JamEvents:   snapshot.JamEvents,
+	}))
+}
+
+// evaluateUnreachableAlerts records a failed metrics collection.
+func evaluateUnreachableAlerts(device *storage.Device) {
+	if device == nil {
+		return
+	}
+	applyAlertResult(localAlertEngine.Unreachable(alertDevice(device), device.LastSeen, time.Now()))
+}
+
+// forgetDeviceAlerts resolves the open alerts of a deleted device.
+func forgetDeviceAlerts(serial string) {
+	if serial != "" {
+		applyAlertResult(localAlertEngine.Forget(serial))
+	}
+}
+
+func alertDevice(device *storage.Device) alerts.Device {
+	return alerts.Device{
+		Serial: device.Serial,
+		IP:     device.IP,
+		Name:   strings.TrimSpace(device.Manufacturer + " " + device.Model),
+	}
+}
+
+// applyAlertResult stores, broadcasts and optionally forwards alert changes.
+func applyAlertResult(res alerts.Result) {
+	if res.Empty() {
+		return
+	}
+	localAlertsMu.Lock()
+	store, forward := localAlertsStore, localAlertsFwd
+	localAlertsMu.Unlock()
+
+	now := time.Now()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	var reports []agent.AlertReport
+	for _, a := range res.Raised {
+		if store != nil {
+			if err := store.AddAlert(ctx, storedAlert(a)); err != nil && appLogger != nil {
+				appLogger.Warn("Failed to store alert", "key", a.Key, "error", err)
+			}
+		}
+		if appLogger != nil {
+			appLogger.Info("Alert raised", "type", a.Type, "serial", a.Serial, "message", a.Message)
+		}
+		broadcastAlert("alert_raised", a)
+		reports = append(reports, alertReport(agent.AlertReportRaised, a, a.RaisedAt))
+	}
+	for _, a := range res.Resolved {
+		if store != nil {
+			if err := store.ResolveAlert(ctx, a.Key, now); err != nil && appLogger != nil {
+				appLogger.Warn("Failed to resolve alert", "key", a.Key, "error", err)
+			}
+		}
+		broadcastAlert("alert_resolved", a)
+		reports = append(reports, alertReport(agent.AlertReportResolved, a, now))
+	}
+	if forward {
+		forwardAlerts(reports)
+	}
+}
+
+func broadcastAlert(eventType string, a alerts.Alert) {
+	if sseHub == nil {
+		return
+	}
+	sseHub.Broadcast(SSEEvent{Type: eventType, Data: map[string]interface{}{
+		"key":      a.Key,
+		"type":     a.Type,
+		"severity": a.Severity,
+		"serial":   a.Serial,
+		"ip":       a.IP,
+		"supply":   a.Supply,
+		"title":    a.Title,
+		"message":  a.Message,
+	}})
+}
+
+// forwardAlerts sends alert changes to the server in the background. Alerts
+// are local-first: a standalone or disconnected agent simply skips this.
+func forwardAlerts(reports []agent.AlertReport) {
+	uploadWorkerMu.RLock()
+	worker := uploadWorker
+	uploadWorkerMu.RUnlock()
+	client := worker.Client()
+	if client == nil || len(reports) == 0 {
+		return
+	}
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+		defer cancel()
+		if err := client.ReportAlerts(ctx, reports); err != nil && appLogger != nil {
+			appLogger.WarnRateLimited("alert_forward", 5*time.Minute, "Failed to forward alerts to server", "error", err)
+		}
+	}()
+}
+
+func alertReport(state string, a alerts.Alert, at time.Time) agent.AlertReport {
+	return agent.AlertReport{
+		State:        state,
+		Type:         a.Type,
+		Severity:     a.Severity,
+		DeviceSerial: a.Serial,
+		DeviceIP:     a.IP,
+		Title:        a.Title,
+		Message:      a.Message,
+		At:           at,
+	}
+}
+
+func storedAlert(a alerts.Alert) *storage.Alert {
+	return &storage.Alert{
+		Key:       a.Key,
+		Type:      a.Type,
+		Severity:  a.Severity,
+		Serial:    a.Serial,
+		IP:        a.IP,
+		Supply:    a.Supply,
+		Title:     a.Title,
+		Message:   a.Message,
+		Value:     a.Value,
+		Threshold: a.Threshold,
+		RaisedAt:  a.RaisedAt,
+	}
+}
+
+func engineAlert(a *storage.Alert) alerts.Alert {
+	return alerts.Alert{
+		Key:       a.Key,
+		Type:      a.Type,
+		Severity:  a.Severity,
+		Serial:    a.Serial,
+		IP:        a.IP,
+		Supply:    a.Supply,
+		Title:     a.Title,
+		Message:   a.Message,
+		Value:     a.Value,
+		Threshold: a.Threshold,
+		RaisedAt:  a.RaisedAt,
+	}
+}
+
+// registerLocalAlertHandlers exposes local alerts and their rules.
+func registerLocalAlertHandlers() {
+	// GET /api/alerts?serial=&open=true&limit= - stored alerts, newest first
+	http.HandleFunc("/api/alerts", handleLocalAlerts)
+	// GET /api/alerts/rules - rules and forwarding
+	// PUT /api/alerts/rules - {"rules": [...], "forward_to_server": bool}
+	http.HandleFunc("/api/alerts/rules", handleLocalAlertRules)
+}
+
+func handleLocalAlerts(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "GET only", http.StatusMethodNotAllowed)
+		return
+	}
+	q := r.URL.Query()
+	filter := storage.AlertFilter{
+		Serial:   strings.TrimSpace(q.Get("serial")),
+		OpenOnly: q.Get("open") == "true",
+		Limit:    200,
+	}
+	if v := q.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > 1000 {
+			http.Error(w, "limit must be between 1 and 1000", http.StatusBadRequest)
+			return
+		}
+		filter.Limit = n
+	}
+
+	localAlertsMu.Lock()
+	store := localAlertsStore
+	localAlertsMu.Unlock()
+	list := []*storage.Alert{}
+	if store != nil {
+		ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+		defer cancel()
+		stored, err := store.ListAlerts(ctx, filter)
+		if err != nil {
+			http.Error(w, "failed to list alerts", http.StatusInternalServerError)
+			return
+		}
+		list = stored
+	} else {
+		// No alert table: serve what the engine has open
+		for _, a := range localAlertEngine.Active() {
+			if filter.Serial == "" || a.Serial == filter.Serial {
+				list = append(list, storedAlert(a))
+			}
+		}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(list)
+}
+
+func handleLocalAlertRules(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		localAlertsMu.Lock()
+		forward := localAlertsFwd
+		localAlertsMu.Unlock()
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(localAlertSettings{Rules: localAlertEngine.Rules(), ForwardToServer: forward})
+	case http.MethodPut:
+		var req localAlertSettings
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "bad json", http.StatusBadRequest)
+			return
+		}
+		if req.Rules == nil {
+			req.Rules = localAlertEngine.Rules()
+		}
+		if err := alerts.ValidateRules(req.Rules); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		localAlertsMu.Lock()
+		config := localAlertsConfig
+		localAlertsMu.Unlock()
+		if config != nil {
+			if err := config.SetConfigValue(localAlertSettingsKey, req); err != nil {
+				http.Error(w, "failed to save alert rules", http.StatusInternalServerError)
+				return
+			}
+		}
+		localAlertsMu.Lock()
+		localAlertsFwd = req.ForwardToServer
+		localAlertsMu.Unlock()
+		localAlertEngine.SetRules(req.Rules)
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(localAlertSettings{Rules: localAlertEngine.Rules(), ForwardToServer: req.ForwardToServer})
+	default:
+		http.Error(w, "GET or PUT only", http.StatusMethodNotAllowed)
+	}
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"printmaster/agent/alerts"
+	"printmaster/agent/storage"
+)
+
+func TestLocalAlertsStoredAndRulesUpdated(t *testing.T) {
+	store, err := storage.NewSQLiteStore(":memory:")
+	if err != nil {
+		t.Fatalf("NewSQLiteStore: %v", err)
+	}
+	defer store.Close()
+	config := newFakeConfigStore()
+	initLocalAlerts(config, store)
+	t.Cleanup(func() {
+		localAlertEngine = alerts.NewEngine(alerts.DefaultRules())
+		initLocalAlerts(nil, nil)
+	})
+
+	dev := &storage.Device{}
+	dev.Serial, dev.IP = "LA1", "10.0.0.9"
+	snap := &storage.MetricsSnapshot{}
+	snap.TonerLevels = map[string]interface{}{"black": 4}
+	evaluateMetricsAlerts(dev, snap)
+
+	rr := httptest.NewRecorder()
+	handleLocalAlerts(rr, httptest.NewRequest(http.MethodGet, "/api/alerts?open=true", nil))
+	var list []storage.Alert
+	if err := json.NewDecoder(rr.Body).Decode(&list); err != nil || len(list) != 1 || list[0].Supply != "black" {
+		t.Fatalf("expected the stored toner alert, got %+v (%v)", list, err)
+	}
+
+	put := func(body string) int {
+		rr := httptest.NewRecorder()
+		handleLocalAlertRules(rr, httptest.NewRequest(http.MethodPut, "/api/alerts/rules", bytes.NewBufferString(body)))
+		return rr.Code
+	}
+	if code := put(`{"rules":[{"type":"toner_low","enabled":true,"threshold":0,"severity":"warning"}]}`); code != http.StatusBadRequest {
+		t.Fatalf("invalid threshold accepted, got %d", code)
+	}
+	if code := put(`{"rules":[{"type":"toner_low","enabled":false,"threshold":10,"severity":"warning"}],"forward_to_server":true}`); code != http.StatusOK {
+		t.Fatalf("unexpected status %d", code)
+	}
+	if _, ok := config.values[localAlertSettingsKey]; !ok {
+		t.Fatalf("alert settings were not saved")
+	}
+
+	// The disabled rule resolves the open alert on the next evaluation
+	evaluateMetricsAlerts(dev, snap)
+	open, _ := store.ListAlerts(context.Background(), storage.AlertFilter{OpenOnly: true})
+	if len(open) != 0 {
+		t.Fatalf("alert still open: %+v", open)
+	}
+}
@@ -32,9 +32,13 @@ import (
 	"path/filepath"
 	"printmaster/agent/agent"
 	"printmaster/agent/autoupdate"
+	"printmaster/agent/devicetls"
 	"printmaster/agent/featureflags"
+	"printmaster/agent/netproxy"
+	"printmaster/agent/plugins"
 	"printmaster/agent/proxy"
 	"printmaster/agent/scanner"
+	"printmaster/agent/scanscope"
 	"printmaster/agent/storage"
 	"printmaster/common/config"
 	"printmaster/common/logger"
@@ -109,12 +113,15 @@ var agentAuth *agentAuthManager
 // globalLocalPrinterStore holds reference to the local printer store for runtime settings changes
 var globalLocalPrinterStore storage.LocalPrinterStore
 
-// AgentPrincipal represents an authenticated UI context (placeholder for future auth)
+// AgentPrincipal represents an authenticated UI context. Role is one of
+// viewer, operator or admin and gates what the principal may do (see authz.go).
 type AgentPrincipal struct {
 	Username  string   `json:"username"`
 	Role      string   `json:"role"`
 	Source    string   `json:"source"`
 	TenantIDs []string `json:"tenant_ids,omitempty"`
+	Scopes    []string `json:"scopes,omitempty"`     // Kiosk principals only
+	GuestLink string   `json:"guest_link,omitempty"` // Guest principals only
 }
 
 type contextKey string
@@ -220,6 +227,8 @@ type agentAuthManager struct {
 	serverCAPath     string
 	serverSkipVerify bool
 	sessions         *agentSessionManager
+	localUsers       map[string]WebAuthUser // keyed by lower-case username
+	kioskTokens      map[string]kioskToken  // keyed by token hash
 	publicExact      map[string]struct{}
 	publicPrefixes   []string
 }
@@ -238,6 +247,8 @@ func newAgentAuthManager(cfg *AgentConfig, sessions *agentSessionManager) *agent
 	serverURL := ""
 	serverCA := ""
 	serverSkip := false
+	localUsers := make(map[string]WebAuthUser)
+	var kioskTokens map[string]kioskToken
 	if cfg != nil {
 		if cfg.Web.Auth.Mode != "" {
 			mode = strings.ToLower(strings.TrimSpace(cfg.Web.Auth.Mode))
@@ -246,6 +257,15 @@ func newAgentAuthManager(cfg *AgentConfig, sessions *agentSessionManager) *agent
 		serverURL = strings.TrimSpace(cfg.Server.URL)
 		serverCA = strings.TrimSpace(cfg.Server.CAPath)
 		serverSkip = cfg.Server.InsecureSkipVerify
+		for _, u := range cfg.Web.Auth.Users {
+			name := strings.TrimSpace(u.Username)
+			if name == "" || u.PasswordHash == "" {
+				continue
+			}
+			u.Username = name
+			localUsers[strings.ToLower(name)] = u
+		}
+		kioskTokens = parseKioskTokens(cfg.Web.Auth.KioskTokens)
 
 		// Auto-enable server mode if server URL is configured and mode not explicitly set
 		if serverURL != "" && cfg.Web.Auth.Mode == "" {
@@ -259,9 +279,13 @@ func newAgentAuthManager(cfg *AgentConfig, sessions *agentSessionManager) *agent
 		serverCAPath:     serverCA,
 		serverSkipVerify: serverSkip,
 		sessions:         sessions,
+		localUsers:       localUsers,
+		kioskTokens:      kioskTokens,
 		publicExact: map[string]struct{}{
 			"/login":                {},
 			"/favicon.ico":          {},
+			"/sw.js":                {}, // PWA service worker and manifest
+			"/manifest.webmanifest": {},
 			"/health":               {},
 			"/api/version":          {},
 			"/api/v1/auth/options":  {},
@@ -283,7 +307,7 @@ func (a *agentAuthManager) optionsPayload() agentAuthOptions {
 	}
 	serverURL := strings.TrimSpace(a.serverURL)
 	hasServer := serverURL != ""
-	loginSupported := hasServer && a.mode == "server"
+	loginSupported := (hasServer && a.mode == "server") || (a.mode != "disabled" && len(a.localUsers) > 0)
 	opts := agentAuthOptions{
 		Mode:            a.mode,
 		AllowLocalAdmin: a.allowLocalAdmin,
@@ -308,25 +332,59 @@ func (a *agentAuthManager) Wrap(next http.Handler) http.Handler {
 			handler.ServeHTTP(w, r)
 			return
 		}
-		principal, ok := a.authenticate(r)
-		if !ok {
-			a.respondUnauthorized(w, r)
+		var principal *AgentPrincipal
+		if r.Header.Get("X-PrintMaster-Proxy") == "server" {
+			// The server authenticated the user; enforce the role it forwarded
+			principal = serverProxyPrincipal(r)
+		} else {
+			var ok bool
+			if principal, ok = a.authenticate(r); !ok {
+				a.respondUnauthorized(w, r)
+				return
+			}
+		}
+		if principal.isKiosk() {
+			if !kioskAllows(principal, r) {
+				http.Error(w, "forbidden: not available to kiosk tokens", http.StatusForbidden)
+				return
+			}
+			if r.URL.Query().Get(kioskTokenQueryParam) != "" && acceptsHTML(r) && a.sessionFromRequest(r) == nil {
+				// Keep the dashboard signed in for the page's own requests
+				_, expiresAt, _ := a.kioskPrincipal(r)
+				if expiresAt.IsZero() || time.Until(expiresAt) > defaultAgentSessionTTL {
+					expiresAt = time.Now().Add(defaultAgentSessionTTL)
+				}
+				a.issueSessionCookie(w, r, principal, "", expiresAt)
+			}
+		}
+		if principal.isGuest() {
+			a.serveGuest(w, r, principal, func(w http.ResponseWriter) {
+				a.serveAuthorized(w, r, principal, handler)
+			})
 			return
 		}
-		ctx := context.WithValue(r.Context(), agentPrincipalContextKey, principal)
-		handler.ServeHTTP(w, r.WithContext(ctx))
+		a.serveAuthorized(w, r, principal, handler)
 	})
 }
 
+// serveAuthorized serves r when the principal's role allows it.
+func (a *agentAuthManager) serveAuthorized(w http.ResponseWriter, r *http.Request, principal *AgentPrincipal, handler http.Handler) {
+	if need := requiredAgentRole(r); !principal.HasRole(need) {
+		if appLogger != nil {
+			appLogger.Debug("Agent UI request denied", "path", r.URL.Path, "method", r.Method,
+				"username", principal.Username, "role", principal.Role, "required", need)
+		}
+		http.Error(w, "forbidden: "+need+" role required", http.StatusForbidden)
+		return
+	}
+	ctx := context.WithValue(r.Context(), agentPrincipalContextKey, principal)
+	handler.ServeHTTP(w, r.WithContext(ctx))
+}
+
 func (a *agentAuthManager) shouldBypass(r *http.Request) bool {
 	if a == nil || a.mode == "disabled" {
 		return true
 	}
-	// Bypass auth for server-proxied requests (header is set by ws_client.go handleProxyRequest)
-	// This is safe because only the internal proxy code path can set this header
-	if r.Header.Get("X-PrintMaster-Proxy") == "server" {
-		return true
-	}
 	path := r.URL.Path
 	if _, ok := a.publicExact[path]; ok {
 		return true
@@ -350,6 +408,12 @@ func (a *agentAuthManager) authenticate(r *http.Request) (*AgentPrincipal, bool)
 	if sess := a.sessionFromRequest(r); sess != nil {
 		return sess.Principal, true
 	}
+	if principal, _, ok := a.kioskPrincipal(r); ok {
+		return principal, true
+	}
+	if principal, _, ok := guestPrincipal(r); ok {
+		return principal, true
+	}
 	if a.allowLocalAdmin && requestIsLoopback(r) {
 		return &AgentPrincipal{Username: "local-admin", Role: "admin", Source: "loopback"}, true
 	}
@@ -444,8 +508,9 @@ func requestIsHTTPS(r *http.Request) bool {
 func handleHealth(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]interface{}{
-		"status":    "healthy",
-		"timestamp": time.Now().UTC(),
+		"status":      "healthy",
+		"timestamp":   time.Now().UTC(),
+		"environment": agentRuntimeEnv,
 	})
 }
 
@@ -593,11 +658,7 @@ func (a *agentAuthManager) handleAuthMe(w http.ResponseWriter, r *http.Request)
 		role := r.Header.Get("X-PrintMaster-Role")
 		if user != "" && role != "" {
 			w.Header().Set("Content-Type", "application/json")
-			json.NewEncoder(w).Encode(&AgentPrincipal{
-				Username: user,
-				Role:     role,
-				Source:   "server-proxy",
-			})
+			json.NewEncoder(w).Encode(serverProxyPrincipal(r))
 			return
 		}
 	}
@@ -633,6 +694,24 @@ func (a *agentAuthManager) handleAuthLogin(w http.ResponseWriter, r *http.Reques
 		http.Error(w, "username and password required", http.StatusBadRequest)
 		return
 	}
+	// Local accounts take precedence over server auth for the same username
+	if _, ok := a.localUsers[strings.ToLower(username)]; ok && a.mode != "disabled" {
+		principal, err := a.localLogin(username, password)
+		if err != nil {
+			http.Error(w, "invalid credentials", http.StatusUnauthorized)
+			return
+		}
+		if _, err := a.issueSessionCookie(w, r, principal, "", time.Time{}); err != nil {
+			http.Error(w, "failed to create session", http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": true,
+			"user":    principal,
+		})
+		return
+	}
 	switch a.mode {
 	case "server":
 		principal, serverToken, expiresAt, err := a.serverLogin(r.Context(), username, password)
@@ -828,7 +907,7 @@ func (a *agentAuthManager) validateServerCallbackToken(ctx context.Context, toke
 
 	principal := &AgentPrincipal{
 		Username: result.Username,
-		Role:     result.Role,
+		Role:     normalizeAgentRole(result.Role),
 		Source:   "server-callback",
 	}
 
@@ -954,7 +1033,7 @@ func (a *agentAuthManager) fetchServerPrincipal(ctx context.Context, client *htt
 	}
 	return &AgentPrincipal{
 		Username:  payload.Username,
-		Role:      payload.Role,
+		Role:      normalizeAgentRole(payload.Role),
 		Source:    "server",
 		TenantIDs: ids,
 	}, nil
@@ -973,10 +1052,76 @@ func (a *agentAuthManager) newServerHTTPClient() (*http.Client, error) {
 		}
 		tlsConfig.RootCAs = pool
 	}
-	transport := &http.Transport{TLSClientConfig: tlsConfig}
+	transport := &http.Transport{Proxy: agent.ProxyForServer, TLSClientConfig: tlsConfig}
 	return &http.Client{Timeout: serverAuthTimeout, Transport: transport}, nil
 }
 
+// newMQTTTransport builds the broker client for the MQTT server transport.
+// In-flight messages are kept under the data directory so a persistent
+// session survives agent restarts.
+func newMQTTTransport(cfg MQTTConfig, agentID, dataDir string) (*agent.MQTTClient, error) {
+	if cfg.QoS < 0 || cfg.QoS > 2 {
+		return nil, fmt.Errorf("invalid MQTT qos %d", cfg.QoS)
+	}
+	opts := agent.MQTTOptions{
+		Broker:       cfg.Broker,
+		ClientID:     cfg.ClientID,
+		Username:     cfg.Username,
+		Password:     cfg.Password,
+		TopicPrefix:  cfg.TopicPrefix,
+		AgentID:      agentID,
+		QoS:          byte(cfg.QoS),
+		CleanSession: cfg.CleanSession,
+		KeepAlive:    time.Duration(cfg.KeepAliveSeconds) * time.Second,
+	}
+	if !cfg.CleanSession && dataDir != "" {
+		opts.StoreDir = filepath.Join(dataDir, "mqtt")
+	}
+	if cfg.CAPath != "" || cfg.CertPath != "" || cfg.InsecureSkipVerify {
+		tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12, InsecureSkipVerify: cfg.InsecureSkipVerify}
+		if cfg.CAPath != "" {
+			pemData, err := os.ReadFile(cfg.CAPath)
+			if err != nil {
+				return nil, fmt.Errorf("failed to read MQTT CA certificate: %w", err)
+			}
+			pool := x509.NewCertPool()
+			if !pool.AppendCertsFromPEM(pemData) {
+				return nil, fmt.Errorf("failed to parse MQTT CA certificate")
+			}
+			tlsConfig.RootCAs = pool
+		}
+		if cfg.CertPath != "" {
+			cert, err := tls.LoadX509KeyPair(cfg.CertPath, cfg.KeyPath)
+			if err != nil {
+				return nil, fmt.Errorf("failed to load MQTT client certificate: %w", err)
+			}
+			tlsConfig.Certificates = []tls.Certificate{cert}
+		}
+		opts.TLSConfig = tlsConfig
+	}
+	return agent.NewMQTTClient(opts)
+}
+
+// configureOutboundProxy installs the proxy used for all traffic to the
+// server. An invalid proxy is logged and the agent connects direct.
+func configureOutboundProxy(cfg ServerProxyConfig) {
+	resolver, err := netproxy.New(cfg.resolverConfig())
+	if err != nil {
+		appLogger.Error("Invalid server proxy configuration, connecting direct", "error", err)
+		return
+	}
+	agent.SetOutboundProxy(resolver)
+	if resolver == nil {
+		return
+	}
+	proxy := cfg.URL
+	if u, err := url.Parse(proxy); err == nil {
+		proxy = u.Redacted()
+	}
+	appLogger.Info("Using outbound proxy for server traffic", "proxy", proxy, "pac_url", cfg.PACURL,
+		"no_proxy", strings.Join(cfg.NoProxy, ","), "bypass_lan", cfg.BypassLAN)
+}
+
 func (a *agentAuthManager) serverAPIURL(p string) string {
 	base := strings.TrimRight(a.serverURL, "/")
 	if base == "" {
@@ -1032,43 +1177,6 @@ func buildAgentCallbackURL(r *http.Request, returnTo string) string {
 	return callbackURL
 }
 
-type staticResourceCache struct {
-	sync.RWMutex
-	items map[string]cachedResource
-}
-
-type cachedResource struct {
-	data        []byte
-	contentType string
-	headers     http.Header
-	expiry      time.Time
-}
-
-func newStaticResourceCache() *staticResourceCache {
-	return &staticResourceCache{items: make(map[string]cachedResource)}
-}
-
-func (c *staticResourceCache) Get(key string) ([]byte, string, http.Header, bool) {
-	c.RLock()
-	defer c.RUnlock()
-	item, ok := c.items[key]
-	if !ok || time.Now().After(item.expiry) {
-		return nil, "", nil, false
-	}
-	return item.data, item.contentType, item.headers, true
-}
-
-func (c *staticResourceCache) Set(key string, data []byte, contentType string, headers http.Header, ttl time.Duration) {
-	c.Lock()
-	defer c.Unlock()
-	c.items[key] = cachedResource{
-		data:        data,
-		contentType: contentType,
-		headers:     headers,
-		expiry:      time.Now().Add(ttl),
-	}
-}
-
 var (
 	staticCache    = newStaticResourceCache()
 	uploadWorkerMu sync.RWMutex
@@ -1194,6 +1302,27 @@ func doGarbageCollection(store storage.DeviceStore, config *agent.RetentionConfi
 	} else if scansDeleted > 0 {
 		appLogger.Info("Garbage collection: Deleted old scan history", "count", scansDeleted, "age_days", config.ScanHistoryDays)
 	}
+	if alertStore, ok := store.(storage.AlertStore); ok {
+		if alertsDeleted, err := alertStore.DeleteResolvedAlertsBefore(ctx, scanHistoryCutoff); err != nil {
+			appLogger.Error("Garbage collection: Failed to delete old alerts", "error", err, "cutoff_days", config.ScanHistoryDays)
+		} else if alertsDeleted > 0 {
+			appLogger.Info("Garbage collection: Deleted resolved alerts", "count", alertsDeleted, "age_days", config.ScanHistoryDays)
+		}
+	}
+	if runStore, ok := store.(storage.ScanRunStore); ok {
+		if runsDeleted, err := runStore.DeleteScanRunsBefore(ctx, scanHistoryCutoff); err != nil {
+			appLogger.Error("Garbage collection: Failed to delete old scan runs", "error", err, "cutoff_days", config.ScanHistoryDays)
+		} else if runsDeleted > 0 {
+			appLogger.Info("Garbage collection: Deleted old scan runs", "count", runsDeleted, "age_days", config.ScanHistoryDays)
+		}
+	}
+	if driftStore, ok := store.(storage.ConfigDriftStore); ok {
+		if eventsDeleted, err := driftStore.DeleteConfigDriftEventsBefore(ctx, scanHistoryCutoff); err != nil {
+			appLogger.Error("Garbage collection: Failed to delete old config drift events", "error", err, "cutoff_days", config.ScanHistoryDays)
+		} else if eventsDeleted > 0 {
+			appLogger.Info("Garbage collection: Deleted old config drift events", "count", eventsDeleted, "age_days", config.ScanHistoryDays)
+		}
+	}
 
 	// Delete old hidden devices
 	if devicesDeleted, err := store.DeleteOldHiddenDevices(ctx, hiddenDevicesCutoff); err != nil {
@@ -1404,15 +1533,39 @@ type deviceStorageAdapter struct {
 }
 
 func (a *deviceStorageAdapter) StoreDiscoveredDevice(ctx context.Context, pi agent.PrinterInfo) error {
+	if !shouldPersistDevice(pi) {
+		return nil
+	}
+
+	ocrResult := applyStatusPageOCR(ctx, &pi)
+
+	var existing *storage.Device
+	if pi.Serial != "" {
+		existing, _ = a.store.Get(ctx, pi.Serial)
+	}
+	checkWebFingerprintChange(existing, pi)
+
 	// Convert PrinterInfo to Device
 	device := storage.PrinterInfoToDevice(pi, false)
 	device.Visible = true
+	preserveServiceScan(device, existing)
+	checkIPConflict(existing, device)
+	enrichDevice(ctx, plugins.StageDiscovery, device, existing, nil)
 
 	snapshot := storage.PrinterInfoToScanSnapshot(pi)
 	metrics := storage.PrinterInfoToMetricsSnapshot(pi)
+	if ocrResult != nil {
+		attachStatusPageOCR(device, snapshot, ocrResult)
+		markStatusPageOCRMetrics(metrics, ocrResult)
+	}
 	if err := a.store.StoreDiscoveryAtomic(ctx, device, snapshot, metrics); err != nil {
 		return fmt.Errorf("failed to persist discovery atomically: %w", err)
 	}
+	if driftStore, ok := a.store.(storage.ConfigDriftStore); ok {
+		checkConfigDrift(ctx, driftStore, pi)
+	}
+	enqueueServiceScan(device)
+	desktopNotifications.deviceStatus(pi)
 
 	// Broadcast device update via SSE
 	if sseHub != nil {
@@ -1571,6 +1724,18 @@ func applyFeaturesSettingsEffects(feat *pmsettings.FeaturesSettings) {
 	if appLogger != nil && effective != previous {
 		appLogger.Info("Epson remote mode updated", "enabled", effective, "config_override", configEnabled)
 	}
+	if featureflags.StatusPageOCREnabled() != feat.StatusPageOCREnabled {
+		featureflags.SetStatusPageOCR(feat.StatusPageOCREnabled)
+		if appLogger != nil {
+			appLogger.Info("Status page OCR fallback updated", "enabled", feat.StatusPageOCREnabled)
+		}
+	}
+	if featureflags.UnknownDeviceTelemetryEnabled() != feat.UnknownDeviceTelemetryEnabled {
+		featureflags.SetUnknownDeviceTelemetry(feat.UnknownDeviceTelemetryEnabled)
+		if appLogger != nil {
+			appLogger.Info("Unknown device telemetry updated", "enabled", feat.UnknownDeviceTelemetryEnabled)
+		}
+	}
 }
 
 func applySpoolerSettings(spooler *pmsettings.SpoolerSettings) {
@@ -1624,6 +1789,7 @@ func applySpoolerSettings(spooler *pmsettings.SpoolerSettings) {
 }
 
 func applyEffectiveSettingsSnapshot(cfg pmsettings.Settings) {
+	recordEffectiveSettings(cfg)
 	if applyDiscoveryEffectsFunc != nil {
 		discMap := structToMap(cfg.Discovery)
 		delete(discMap, "ranges_text")
@@ -1631,6 +1797,7 @@ func applyEffectiveSettingsSnapshot(cfg pmsettings.Settings) {
 		applyDiscoveryEffectsFunc(discMap)
 	}
 	applyFeaturesSettingsEffects(&cfg.Features)
+	applySNMPSweepSettings(&cfg.SNMP)
 }
 
 func loadUnifiedSettings(store storage.AgentConfigStore) pmsettings.Settings {
@@ -1674,9 +1841,15 @@ func loadUnifiedSettings(store storage.AgentConfigStore) pmsettings.Settings {
 		if webRaw, ok := unified["web"].(map[string]interface{}); ok {
 			mapIntoStruct(webRaw, &base.Web)
 		}
+		// Notification settings (agent-local, always allow local override)
+		if notifyRaw, ok := unified["notifications"].(map[string]interface{}); ok {
+			mapIntoStruct(notifyRaw, &base.Notifications)
+		}
 	}
 	pmsettings.Sanitize(&base)
 	applyFeaturesSettingsEffects(&base.Features)
+	applySNMPSweepSettings(&base.SNMP)
+	desktopNotifications.setPreferences(base.Notifications)
 	return base
 }
 
@@ -1764,6 +1937,9 @@ type serverConnectionStatus struct {
 	HasJoinToken       bool       `json:"has_join_token"`
 	WebSocketEnabled   bool       `json:"websocket_enabled"`
 	WebSocketConnected bool       `json:"websocket_connected"`
+	// Health classifies the last server request and reports whether the
+	// circuit breaker is pausing uploads
+	Health *agent.ServerHealthSnapshot `json:"health,omitempty"`
 }
 
 var (
@@ -1795,6 +1971,8 @@ func snapshotServerConnectionStatus(agentCfg *AgentConfig, dataDir string) serve
 		status.LastMetricsUpload = timePtr(wStatus.LastMetricsUpload)
 		status.WebSocketEnabled = wStatus.WebSocketEnabled
 		status.WebSocketConnected = wStatus.WebSocketConnected
+		health := wStatus.Health
+		status.Health = &health
 	} else {
 		status.Connected = false
 	}
@@ -1804,6 +1982,8 @@ func snapshotServerConnectionStatus(agentCfg *AgentConfig, dataDir string) serve
 			mode = "connected"
 			if status.WebSocketEnabled && status.WebSocketConnected {
 				mode = "live"
+			} else if status.Health != nil && status.Health.Status != agent.ServerHealthOK && status.Health.Status != agent.ServerHealthUnknown {
+				mode = "degraded"
 			}
 		}
 	}
@@ -1964,7 +2144,12 @@ func startServerUploadWorker(
 	if agentCfg == nil {
 		return nil, fmt.Errorf("agent configuration unavailable")
 	}
-	if strings.TrimSpace(agentCfg.Server.URL) == "" {
+	useMQTT := agentCfg.Server.usesMQTT()
+	if useMQTT {
+		if strings.TrimSpace(agentCfg.Server.MQTT.Broker) == "" {
+			return nil, fmt.Errorf("MQTT broker not configured")
+		}
+	} else if strings.TrimSpace(agentCfg.Server.URL) == "" {
 		return nil, fmt.Errorf("server URL not configured")
 	}
 
@@ -1988,6 +2173,7 @@ func startServerUploadWorker(
 
 	workerLogger.Info("Server integration enabled",
 		"url", agentCfg.Server.URL,
+		"transport", agentCfg.Server.Transport,
 		"agent_id", agentID,
 		"agent_name", agentName,
 		"ca_path", agentCfg.Server.CAPath,
@@ -2007,6 +2193,9 @@ func startServerUploadWorker(
 		agentCfg.Server.CAPath,
 		agentCfg.Server.InsecureSkipVerify,
 	)
+	if err := loadMeterReadKey(dataDir, serverClient); err != nil {
+		workerLogger.Warn("Failed to load meter read signing key; meter reads will not be captured", "error", err)
+	}
 
 	workerConfig := UploadWorkerConfig{
 		HeartbeatInterval: time.Duration(agentCfg.Server.HeartbeatInterval) * time.Second,
@@ -2021,6 +2210,14 @@ func startServerUploadWorker(
 	if workerConfig.UploadInterval <= 0 {
 		workerConfig.UploadInterval = 5 * time.Minute
 	}
+	if useMQTT {
+		mqttClient, err := newMQTTTransport(agentCfg.Server.MQTT, agentID, dataDir)
+		if err != nil {
+			return nil, err
+		}
+		workerConfig.MQTT = mqttClient
+		workerLogger.Info("Using MQTT transport", "broker", agentCfg.Server.MQTT.Broker)
+	}
 
 	uploadWorker := NewUploadWorker(serverClient, deviceStore, workerLogger, settings, workerConfig, dataDir)
 
@@ -2136,7 +2333,7 @@ func performServerJoin(
 	if joinToken == "" {
 		return nil, newJoinError(http.StatusBadRequest, fmt.Errorf("token required"))
 	}
-	dataDir, err := config.GetDataDirectory("agent", isSvc)
+	dataDir, err := agentDataDirectory()
 	if err != nil {
 		return nil, newJoinError(http.StatusInternalServerError, fmt.Errorf("failed to determine data directory: %w", err))
 	}
@@ -2147,6 +2344,9 @@ func performServerJoin(
 	caPath := strings.TrimSpace(params.CAPath)
 	agentName := resolveAgentDisplayName(agentCfg, params.AgentName)
 	client := agent.NewServerClientWithName(serverURL, agentID, agentName, "", caPath, params.Insecure)
+	if err := loadMeterReadKey(dataDir, client); err != nil {
+		return nil, newJoinError(http.StatusInternalServerError, fmt.Errorf("failed to load meter read signing key: %w", err))
+	}
 	agentToken, tenantID, err := client.RegisterWithToken(reqCtx, joinToken, Version)
 	if err != nil {
 		return nil, newJoinError(http.StatusBadGateway, err)
@@ -2301,6 +2501,11 @@ func tryLearnOIDForValue(ctx context.Context, ip string, vendorHint string, fiel
 }
 
 func main() {
+	// Headless subcommands (scan, collect, export) have their own flags
+	if handled, code := runCLICommand(os.Args[1:]); handled {
+		os.Exit(code)
+	}
+
 	// Parse command-line flags for service management
 	configPath := flag.String("config", "config.toml", "Configuration file path")
 	generateConfig := flag.Bool("generate-config", false, "Generate default config file and exit")
@@ -2311,6 +2516,14 @@ func main() {
 	silent := flag.Bool("silent", false, "Suppress ALL output (complete silence)")
 	flag.BoolVar(silent, "s", false, "Shorthand for --silent")
 	healthCheck := flag.Bool("health", false, "Perform local health check against /health and exit")
+	flag.Usage = func() {
+		out := flag.CommandLine.Output()
+		fmt.Fprintln(out, "Usage: printmaster-agent [flags]")
+		fmt.Fprintln(out, "       printmaster-agent <scan|collect|export> [flags]  (run '<command> -h' for details)")
+		fmt.Fprintln(out, "       printmaster-agent --scan CIDR[,CIDR...] [--format json|csv|table]  (one-shot scan, saves nothing)")
+		fmt.Fprintln(out, "\nFlags:")
+		flag.PrintDefaults()
+	}
 	flag.Parse()
 
 	// Set quiet/silent mode globally for util functions
@@ -2351,6 +2564,12 @@ func main() {
 		return
 	}
 
+	// Supervised worker process (watchdog child-process mode)
+	if isWatchdogChild() {
+		runWatchdogWorker("")
+		return
+	}
+
 	// Handle service commands
 	if *serviceCmd != "" {
 		handleServiceCommand(*serviceCmd)
@@ -2763,15 +2982,9 @@ func runInteractive(ctx context.Context, configFlag string) {
 	sseHub = NewSSEHub()
 
 	// Initialize structured logger (DEBUG level for proxy diagnostics, 1000 entries in buffer)
-	// Determine log directory based on whether we're running as a service
-	var logDir string
-	if !service.Interactive() {
-		// Running as service - use platform-specific system directory
-		logPath := getServiceLogPath()
-		logDir = filepath.Dir(logPath)
-	} else {
-		logDir = "logs"
-	}
+	// Log directory and console output depend on where the agent runs
+	runtimeEnv := detectRuntimeEnvironment()
+	logDir := agentLogDirectory()
 
 	if err := os.MkdirAll(logDir, 0755); err == nil {
 		appLogger = logger.New(logger.DEBUG, logDir, 1000)
@@ -2783,9 +2996,9 @@ func runInteractive(ctx context.Context, configFlag string) {
 			MaxAgeDays: 7,
 			MaxFiles:   5,
 		})
-		// Disable console output when running as service to avoid flooding syslog/journal.
+		// Disable console output under a service manager to avoid flooding syslog/journal.
 		// The agent already writes to its own rotated log files in logDir.
-		if !service.Interactive() {
+		if !runtimeEnv.ConsoleLogging() {
 			appLogger.SetConsoleOutput(false)
 		}
 		// Set up SSE broadcasting for log entries
@@ -2816,7 +3029,8 @@ func runInteractive(ctx context.Context, configFlag string) {
 			"version", Version,
 			"build_time", BuildTime,
 			"git_commit", GitCommit,
-			"build_type", BuildType)
+			"build_type", BuildType,
+			"runtime_environment", runtimeEnv)
 	}
 
 	// Provide the app logger to the agent package so internal logs are structured
@@ -2828,19 +3042,13 @@ func runInteractive(ctx context.Context, configFlag string) {
 	// Interactive mode: executable dir > current dir
 	var agentConfig *AgentConfig
 
-	isService := !service.Interactive()
+	isService := runningAsService()
+	desktopNotifications.setInteractive(!isService)
 	var configPaths []string
 
 	if isService {
 		// Running as service - check ProgramData locations only
-		programData := os.Getenv("ProgramData")
-		if programData == "" {
-			programData = "C:\\ProgramData"
-		}
-		configPaths = []string{
-			filepath.Join(programData, "PrintMaster", "agent", "config.toml"),
-			filepath.Join(programData, "PrintMaster", "config.toml"), // Legacy location
-		}
+		configPaths = serviceConfigPaths()
 	} else {
 		// Running interactively - check local locations only
 		configPaths = []string{
@@ -2888,6 +3096,7 @@ func runInteractive(ctx context.Context, configFlag string) {
 	configEpsonRemoteModeEnabled = agentConfig != nil && agentConfig.EpsonRemoteModeEnabled
 	featureflags.SetEpsonRemoteMode(configEpsonRemoteModeEnabled)
 	agentAuth = newAgentAuthManager(agentConfig, agentSessions)
+	configureOutboundProxy(agentConfig.Server.Proxy)
 
 	// Always apply environment overrides for database path (supports AGENT_DB_PATH and DB_PATH)
 	// even when using default configuration (no config file present).
@@ -2929,6 +3138,9 @@ func runInteractive(ctx context.Context, configFlag string) {
 		appLogger.Info("Log level set from config", "level", agentConfig.Logging.Level)
 	}
 
+	startAgentWatchdog(ctx, agentConfig.Watchdog, logDir)
+	initEnrichmentPlugins(agentConfig.Plugins)
+
 	// Initialize device storage
 	// Use config-specified path or detect proper data directory for service
 	var dbPath string
@@ -2938,7 +3150,7 @@ func runInteractive(ctx context.Context, configFlag string) {
 		appLogger.Info("Using configured database path", "path", dbPath)
 	} else {
 		// Detect if running as service and use appropriate directory
-		dataDir, dirErr := config.GetDataDirectory("agent", isService)
+		dataDir, dirErr := agentDataDirectory()
 		if dirErr != nil {
 			appLogger.Warn("Could not get data directory, using in-memory storage", "error", dirErr)
 			dbPath = ":memory:"
@@ -2956,6 +3168,15 @@ func runInteractive(ctx context.Context, configFlag string) {
 	if dbPath == ":memory:" {
 		agentDBPath = ":memory:"
 	}
+	if dbPath != ":memory:" {
+		if err := ConfigureDatabaseEncryption(agentConfig.DatabaseEncryption, filepath.Dir(dbPath)); err != nil {
+			appLogger.Error("Failed to enable database encryption", "error", err)
+			os.Exit(1)
+		}
+		if agentConfig.DatabaseEncryption.Enabled {
+			appLogger.Info("Database encryption at rest enabled", "path", storage.EncryptedPath(dbPath))
+		}
+	}
 	agentConfigStore, err = storage.NewAgentConfigStore(agentDBPath)
 	if err != nil {
 		appLogger.Error("Failed to initialize agent config storage", "error", err, "path", agentDBPath)
@@ -2964,6 +3185,15 @@ func runInteractive(ctx context.Context, configFlag string) {
 	defer agentConfigStore.Close()
 	appLogger.Info("Agent config database initialized", "path", agentDBPath)
 	settingsManager = NewSettingsManager(agentConfigStore)
+	initCommunitySweep(agentConfigStore)
+	initPortOverrides(agentConfigStore)
+	initIPConflicts(agentConfigStore)
+	guestDataDir := ""
+	if dbPath != ":memory:" {
+		guestDataDir = filepath.Dir(dbPath)
+	}
+	initGuestLinks(agentConfigStore, guestDataDir, agentLogDirectory())
+	go runStatusMonitor(ctx)
 	applyServerConfigFromStore(agentConfig, agentConfigStore, appLogger)
 
 	// Migration: consolidate legacy dev_settings / developer_settings / security_settings into unified "settings" key
@@ -3120,6 +3350,11 @@ func runInteractive(ctx context.Context, configFlag string) {
 	if err := storage.CleanupOldBackups(dbPath, 10); err != nil {
 		appLogger.Warn("Failed to cleanup old database backups", "error", err)
 	}
+	if agentConfig.DatabaseEncryption.Enabled {
+		if err := storage.CleanupOldBackups(storage.EncryptedPath(dbPath), 10); err != nil {
+			appLogger.Warn("Failed to cleanup old encrypted database backups", "error", err)
+		}
+	}
 
 	// Initialize device storage with config store for rotation tracking
 	deviceStore, err = storage.NewSQLiteStoreWithConfig(dbPath, agentConfigStore)
@@ -3128,6 +3363,8 @@ func runInteractive(ctx context.Context, configFlag string) {
 		os.Exit(1)
 	}
 	defer deviceStore.Close()
+	startServiceScanner(ctx)
+	startMeterReadScheduler(ctx)
 
 	// Load and restore trace tags from config
 	var savedTraceTags map[string]bool
@@ -3140,6 +3377,7 @@ func runInteractive(ctx context.Context, configFlag string) {
 	dataDir := filepath.Dir(dbPath)
 	broadcastServerStatus(agentConfig, dataDir, "initial", true)
 	startServerStatusMonitor(ctx, agentConfig, dataDir, 5*time.Second)
+	startStaticCachePersistence(ctx, dataDir)
 	secretPath := filepath.Join(dataDir, "agent_secret.key")
 	secretKey, skErr := commonutil.LoadOrCreateKey(secretPath)
 	if skErr != nil {
@@ -3147,6 +3385,8 @@ func runInteractive(ctx context.Context, configFlag string) {
 	} else {
 		appLogger.Debug("Secret key loaded", "path", secretPath)
 	}
+	initDeviceTLS(agentConfigStore, secretKey)
+	initSNMPProfiles(agentConfigStore, secretKey)
 
 	// Helpers for WebUI credential storage
 	type credRecord struct {
@@ -3227,6 +3467,15 @@ func runInteractive(ctx context.Context, configFlag string) {
 	// Start metrics downsampler goroutine (runs every 6 hours)
 	go runMetricsDownsampler(ctx, deviceStore)
 
+	// Resume high-frequency device watches that outlived the last run
+	initDeviceWatches(ctx, deviceStore)
+
+	// Local alert rules and the alerts left open by the last run
+	initLocalAlerts(agentConfigStore, deviceStore)
+
+	// Merge records stored under a stand-in serial into the real device (runs every 6 hours)
+	go runDeviceReconciler(ctx, deviceStore)
+
 	// Auto-discovery management (periodic scanning + optional live discovery methods)
 	// Controlled by discovery setting: auto_discover_enabled (bool) - master switch
 	// Individual live discovery methods can be enabled/disabled independently
@@ -3285,6 +3534,7 @@ func runInteractive(ctx context.Context, configFlag string) {
 
 			// Run immediately on start
 			runPeriodicScan := func() {
+				defer watchdogBegin("auto_discover")()
 				appLogger.Debug("Auto Discover: running periodic scan")
 
 				// Load discovery settings
@@ -3323,10 +3573,14 @@ func runInteractive(ctx context.Context, configFlag string) {
 				}
 
 				// Use new scanner for periodic discovery (full mode)
-				_, err := Discover(ctx, ranges, "full", discoveryCfg, deviceStore, 50, 10)
+				scanStart := time.Now()
+				printers, err := Discover(ctx, ranges, "full", discoveryCfg, deviceStore, 50, 10)
 				if err != nil && ctx.Err() == nil {
 					appLogger.Error("Auto Discover scan error", "error", err, "ranges", len(ranges))
 				}
+				if ctx.Err() == nil {
+					recordScanRun(scanRunSourceAuto, "full", ranges, scanStart, printers, err)
+				}
 			}
 			runPeriodicScan()
 
@@ -3372,6 +3626,12 @@ func runInteractive(ctx context.Context, configFlag string) {
 	handleLiveDiscovery := func(ip string, discoveryMethod string) {
 		ctx := context.Background()
 
+		// Live announcements can come from any reachable network; only
+		// follow up on those inside the authorized scan scope
+		if scanscope.Check(ip, "live:"+discoveryMethod) != nil {
+			return
+		}
+
 		// Check if we already know this IP from a saved device
 		// If so, do a quick refresh instead of full detection
 		if deviceStore != nil {
@@ -3524,6 +3784,7 @@ func runInteractive(ctx context.Context, configFlag string) {
 		// Store/update the device
 		agent.UpsertDiscoveredPrinter(*pi)
 	}
+	liveDiscoveryFunc = handleLiveDiscovery
 
 	// Live mDNS discovery worker (only works when auto discover is enabled)
 	startLiveMDNS := func() {
@@ -3692,97 +3953,20 @@ func runInteractive(ctx context.Context, configFlag string) {
 		snmpTrapCancel = cancel
 		snmpTrapRunning = true
 
-		appLogger.Info("SNMP Trap: starting listener", "port", 162, "requires_admin", true)
+		appLogger.Info("SNMP Trap: starting listener", "port", agent.DefaultTrapPort, "fallback_port", trapFallbackPort())
 
 		go func() {
-			h := func(ip string) bool {
-				// Async SNMP enrichment + metrics collection
-				go func(ip string) {
-					// Use new scanner for trap handling
-					ctx := context.Background()
-					pi, err := LiveDiscoveryDetect(ctx, ip, getSNMPTimeoutSeconds())
-					if err != nil {
-						appLogger.WarnRateLimited("trap_enrich_"+ip, 5*time.Minute, "SNMP Trap: enrichment failed", "ip", ip, "error", err)
-						return
-					}
-
-					serial := pi.Serial
-					if serial == "" {
-						appLogger.Debug("SNMP Trap: no serial found for device", "ip", ip)
-						return
-					}
-
-					// Check if device exists in DB
-					existing, err := deviceStore.Get(ctx, serial)
-					if err == nil && existing != nil {
-						// Known device - update LastSeen
-						existing.LastSeen = time.Now()
-						existing.IP = ip
-						if updateErr := deviceStore.Update(ctx, existing); updateErr == nil {
-							appLogger.Debug("SNMP Trap: known device updated", "ip", ip, "serial", serial)
-						}
-					} else {
-						// New device
-						appLogger.Debug("SNMP Trap: new device discovered", "ip", ip, "serial", serial)
-					}
-
-					// Store/update the device
-					agent.UpsertDiscoveredPrinter(*pi)
-					appLogger.Info("SNMP Trap: discovered device", "ip", ip, "serial", serial)
-
-					// If metrics monitoring is enabled and device is saved, collect metrics immediately
+			traps := &snmpTrapHandler{
+				timeoutSeconds: getSNMPTimeoutSeconds,
+				metricsEnabled: func() bool {
 					metricsRescanMu.Lock()
-					metricsEnabled := metricsRescanRunning
-					metricsRescanMu.Unlock()
-
-					if metricsEnabled && deviceStore != nil && serial != "" {
-						// Check if device is saved
-						ctx := context.Background()
-						device, err := deviceStore.Get(ctx, serial)
-						if err == nil && device != nil && device.IsSaved {
-							// Extract learned OIDs from device for efficient metrics collection
-							pi := storage.DeviceToPrinterInfo(device)
-							learnedOIDs := &pi.LearnedOIDs
-
-							// Collect metrics for this device using learned OIDs if available
-							agentSnapshot, err := CollectMetricsWithOIDs(ctx, ip, serial, device.Manufacturer, 10, learnedOIDs)
-							if err != nil {
-								appLogger.WarnRateLimited("trap_metrics_"+serial, 5*time.Minute, "SNMP Trap: metrics collection failed", "serial", serial, "error", err)
-							} else {
-								// Convert to storage format
-								storageSnapshot := &storage.MetricsSnapshot{}
-								storageSnapshot.Serial = agentSnapshot.Serial
-								storageSnapshot.Timestamp = time.Now()
-								storageSnapshot.PageCount = agentSnapshot.PageCount
-								storageSnapshot.ColorPages = agentSnapshot.ColorPages
-								storageSnapshot.MonoPages = agentSnapshot.MonoPages
-								storageSnapshot.ScanCount = agentSnapshot.ScanCount
-								storageSnapshot.TonerLevels = agentSnapshot.TonerLevels
-								storageSnapshot.FaxPages = agentSnapshot.FaxPages
-								storageSnapshot.CopyPages = agentSnapshot.CopyPages
-								storageSnapshot.OtherPages = agentSnapshot.OtherPages
-								storageSnapshot.CopyMonoPages = agentSnapshot.CopyMonoPages
-								storageSnapshot.CopyFlatbedScans = agentSnapshot.CopyFlatbedScans
-								storageSnapshot.CopyADFScans = agentSnapshot.CopyADFScans
-								storageSnapshot.FaxFlatbedScans = agentSnapshot.FaxFlatbedScans
-								storageSnapshot.FaxADFScans = agentSnapshot.FaxADFScans
-								storageSnapshot.ScanToHostFlatbed = agentSnapshot.ScanToHostFlatbed
-								storageSnapshot.ScanToHostADF = agentSnapshot.ScanToHostADF
-								storageSnapshot.DuplexSheets = agentSnapshot.DuplexSheets
-								storageSnapshot.JamEvents = agentSnapshot.JamEvents
-								storageSnapshot.ScannerJamEvents = agentSnapshot.ScannerJamEvents
-
-								// Save to database (error already logged in storage layer)
-								if err := deviceStore.SaveMetricsSnapshot(ctx, storageSnapshot); err == nil {
-									appLogger.Debug("SNMP Trap: collected metrics", "serial", serial)
-								}
-							}
-						}
-					}
-				}(ip)
-				return true
-			} // Call browser with 10-minute throttle window
-			agent.StartSNMPTrapBrowser(ctx, h, snmpTrapSeen, 10*time.Minute)
+					defer metricsRescanMu.Unlock()
+					return metricsRescanRunning
+				},
+			}
+			// Discovery traps are throttled per device for 10 minutes;
+			// supply and jam traps per supply for at most a minute
+			agent.StartSNMPTrapBrowser(ctx, traps.handle, snmpTrapSeen, 10*time.Minute, trapFallbackPort)
 
 			snmpTrapMu.Lock()
 			snmpTrapRunning = false
@@ -3830,6 +4014,10 @@ func runInteractive(ctx context.Context, configFlag string) {
 					}
 				}
 
+				if scanscope.Check(ip, "live:llmnr") != nil {
+					return true
+				}
+
 				// Async SNMP enrichment
 				go func(ip, hostname string) {
 					ctx := context.Background()
@@ -3949,8 +4137,11 @@ func runInteractive(ctx context.Context, configFlag string) {
 	// Define the collection function
 	// Collect metrics from ALL devices (saved + discovered) for tiered storage
 	collectMetricsForSavedDevices = func() {
+		defer watchdogBegin("metrics_rescan")()
 		appLogger.Debug("Metrics rescan: collecting snapshots from all devices")
 		ctx := context.Background()
+		started := time.Now()
+		defer func() { agentPromStats.recordCollectionRun(time.Since(started)) }()
 
 		// Get all devices (no IsSaved filter - collect from discovered devices too)
 		devices, err := deviceStore.List(ctx, storage.DeviceFilter{})
@@ -3967,8 +4158,13 @@ func runInteractive(ctx context.Context, configFlag string) {
 
 			// Collect metrics snapshot using learned OIDs if available
 			agentSnapshot, err := CollectMetricsWithOIDs(ctx, device.IP, device.Serial, device.Manufacturer, 10, learnedOIDs)
+			if err != nil && relocator.Relocate(ctx, deviceStore, device) {
+				agentSnapshot, err = CollectMetricsWithOIDs(ctx, device.IP, device.Serial, device.Manufacturer, 10, learnedOIDs)
+			}
+			agentPromStats.recordCollection(device.Serial, err)
 			if err != nil {
 				appLogger.WarnRateLimited("metrics_collect_"+device.Serial, 5*time.Minute, "Metrics rescan: collection failed", "serial", device.Serial, "ip", device.IP, "error", err)
+				evaluateUnreachableAlerts(device)
 				continue
 			}
 
@@ -3991,13 +4187,25 @@ func runInteractive(ctx context.Context, configFlag string) {
 			storageSnapshot.ScanToHostFlatbed = agentSnapshot.ScanToHostFlatbed
 			storageSnapshot.ScanToHostADF = agentSnapshot.ScanToHostADF
 			storageSnapshot.DuplexSheets = agentSnapshot.DuplexSheets
+			storageSnapshot.SimplexSheets = agentSnapshot.SimplexSheets
+			storageSnapshot.NUpSheets = agentSnapshot.NUpSheets
 			storageSnapshot.JamEvents = agentSnapshot.JamEvents
 			storageSnapshot.ScannerJamEvents = agentSnapshot.ScannerJamEvents
+			ocrResult := applyStatusPageOCRMetrics(ctx, device, storageSnapshot)
 
 			// Save to database (error already logged in storage layer)
 			if err := deviceStore.SaveMetricsSnapshot(ctx, storageSnapshot); err != nil {
 				continue
 			}
+			evaluateMetricsAlerts(device, storageSnapshot)
+			if ocrResult != nil {
+				attachStatusPageOCR(device, nil, ocrResult)
+			}
+			if enrichDevice(ctx, plugins.StageMetrics, device, nil, storageSnapshot) || ocrResult != nil {
+				if err := deviceStore.Update(ctx, device); err != nil {
+					appLogger.Warn("Metrics rescan: failed to save plugin fields", "serial", device.Serial, "error", err)
+				}
+			}
 
 			count++
 		}
@@ -4010,6 +4218,11 @@ func runInteractive(ctx context.Context, configFlag string) {
 		if req == nil {
 			return
 		}
+		applyPersistFilterSettings(req)
+		applyScanScopeSettings(req)
+		applyServiceScanSettings(req)
+		applyMeterReadSettings(req)
+		applySNMPTrapSettings(req)
 		autoDiscoverEnabled := false
 		if v, ok := req["auto_discover_enabled"]; ok {
 			if vb, ok2 := v.(bool); ok2 {
@@ -4155,7 +4368,7 @@ func runInteractive(ctx context.Context, configFlag string) {
 			appLogger.Debug("Range preview", "line", strings.TrimSpace(lines[i]))
 		}
 		// Validate the ranges
-		res, err := agent.ParseRangeText(rangesText, 4096)
+		res, err := agent.ParseRangeText(rangesText, discoveryMaxAddresses)
 		if err != nil {
 			appLogger.Error("Failed to parse saved ranges", "error", err.Error())
 		} else if len(res.Errors) > 0 {
@@ -4227,7 +4440,7 @@ func runInteractive(ctx context.Context, configFlag string) {
 
 	// Load server configuration from TOML and start upload worker
 	if agentConfig != nil && agentConfig.Server.Enabled {
-		dataDir, err := config.GetDataDirectory("agent", isService)
+		dataDir, err := agentDataDirectory()
 		if err != nil {
 			appLogger.Error("Failed to get data directory", "error", err)
 			return
@@ -4264,6 +4477,8 @@ func runInteractive(ctx context.Context, configFlag string) {
 			applyDiscoveryEffects(discoverySettings)
 		}
 	}
+	// Passive pluggable discovery methods run independently of auto-discover
+	startDiscoveryMethodListeners()
 
 	// Ensure key handlers are registered (register sandbox explicitly so it's
 	// always present regardless of init ordering in other files). Use a
@@ -4271,6 +4486,9 @@ func runInteractive(ctx context.Context, configFlag string) {
 
 	// Lightweight health endpoint for Docker/monitoring (public).
 	http.HandleFunc("/health", handleHealth)
+	http.HandleFunc("/api/v1/status/summary", handleStatusSummary)
+	http.HandleFunc("/api/v1/traps", handleRecentTraps)
+	http.HandleFunc("/api/v1/traps/status", handleTrapStatus)
 
 	// Serve the UI only for the exact root path and GET method. This prevents
 	// the UI HTML from being returned as a fallback for other endpoints (e.g.
@@ -4357,11 +4575,17 @@ func runInteractive(ctx context.Context, configFlag string) {
 			w.Header().Set("Content-Type", "text/css; charset=utf-8")
 		} else if strings.HasSuffix(filePath, ".js") {
 			w.Header().Set("Content-Type", "application/javascript; charset=utf-8")
+		} else if strings.HasSuffix(filePath, ".svg") {
+			w.Header().Set("Content-Type", "image/svg+xml")
 		}
 
 		w.Write(content)
 	})
 
+	// Installable offline shell (see pwa.go)
+	http.HandleFunc("/sw.js", handleServiceWorker)
+	http.HandleFunc("/manifest.webmanifest", handleWebManifest)
+
 	http.HandleFunc("/login", func(w http.ResponseWriter, r *http.Request) {
 		if r.Method != http.MethodGet {
 			http.Error(w, "only GET allowed", http.StatusMethodNotAllowed)
@@ -4398,9 +4622,13 @@ func runInteractive(ctx context.Context, configFlag string) {
 		// Create client and register with hub
 		client := sseHub.NewClient()
 		defer sseHub.RemoveClient(client)
+		principal, _ := r.Context().Value(agentPrincipalContextKey).(*AgentPrincipal)
 
-		// Send initial connection event
-		fmt.Fprintf(w, "event: connected\ndata: {\"message\":\"Connected to event stream\"}\n\n")
+		// Send initial connection event with a reconnect hint that backs off
+		// while the agent is degraded
+		overall := agentStatus.overall()
+		fmt.Fprintf(w, "retry: %d\nevent: connected\ndata: {\"message\":\"Connected to event stream\",\"status\":%q}\n\n",
+			sseRetryHint(overall).Milliseconds(), overall)
 		flusher.Flush()
 
 		// Send periodic keepalive comments to prevent idle timeouts in proxies
@@ -4414,17 +4642,30 @@ func runInteractive(ctx context.Context, configFlag string) {
 		for {
 			select {
 			case event := <-client.events:
+				if !kioskReceivesEvent(principal, event.Type) {
+					continue
+				}
 				// Marshal event data
 				data, err := json.Marshal(event.Data)
 				if err != nil {
 					continue
 				}
 
-				// Send SSE formatted event
+				// Send SSE formatted event; state changes also update the
+				// reconnect hint
+				if event.Type == statusChangedEvent {
+					fmt.Fprintf(w, "retry: %d\n", sseRetryHint(fmt.Sprint(event.Data["overall"])).Milliseconds())
+				}
 				fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event.Type, string(data))
 				flusher.Flush()
 
 			case <-ticker.C:
+				if principal.isGuest() {
+					// End the stream once the guest link is revoked or expires
+					if _, ok := guestLinks.active(principal.GuestLink); !ok {
+						return
+					}
+				}
 				// Keepalive comment for EventSource; ignored by client but prevents
 				// idle connection timeouts in proxies and network middleboxes.
 				// Format: comment line starting with ':' followed by a blank line.
@@ -4530,7 +4771,10 @@ func runInteractive(ctx context.Context, configFlag string) {
 
 		// Use new scanner for all discovery
 		ctx := context.Background()
+		scanStart := time.Now()
 		printers, err := Discover(ctx, ranges, mode, discoveryCfg, deviceStore, conc, timeoutSeconds)
+		desktopNotifications.scanCompleted(len(printers), time.Since(scanStart), err)
+		recordScanRun(scanRunSourceManual, mode, ranges, scanStart, printers, err)
 		if err != nil {
 			http.Error(w, err.Error(), http.StatusInternalServerError)
 			return
@@ -4816,6 +5060,16 @@ func runInteractive(ctx context.Context, configFlag string) {
 		json.NewEncoder(w).Encode(lines)
 	})
 
+	// Anonymized catalog of unrecognized printers, as sent by the opt-in
+	// unknown-device telemetry
+	http.HandleFunc("/unknown_devices", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"telemetry_enabled": featureflags.UnknownDeviceTelemetryEnabled(),
+			"devices":           agent.UnknownDevices(),
+		})
+	})
+
 	// Endpoint to fetch parse debug for an IP (returns in-memory snapshot or persisted JSON)
 	http.HandleFunc("/parse_debug", func(w http.ResponseWriter, r *http.Request) {
 		q := r.URL.Query()
@@ -5062,6 +5316,8 @@ func runInteractive(ctx context.Context, configFlag string) {
 						"scan_to_host_flatbed": m.ScanToHostFlatbed,
 						"scan_to_host_adf":     m.ScanToHostADF,
 						"duplex_sheets":        m.DuplexSheets,
+						"simplex_sheets":       m.SimplexSheets,
+						"nup_sheets":           m.NUpSheets,
 						"jam_events":           m.JamEvents,
 						"scanner_jam_events":   m.ScannerJamEvents,
 						"tier":                 m.Tier,
@@ -5508,6 +5764,9 @@ func runInteractive(ctx context.Context, configFlag string) {
 			http.Error(w, "unable to determine target ip for refresh", http.StatusBadRequest)
 			return
 		}
+		if rejectOutOfScopeTarget(w, targetIP, "refresh") {
+			return
+		}
 		ctx := context.Background()
 		pi, err := LiveDiscoveryDetect(ctx, targetIP, getSNMPTimeoutSeconds())
 		if err != nil {
@@ -5647,6 +5906,9 @@ func runInteractive(ctx context.Context, configFlag string) {
 			http.Error(w, "ip required", http.StatusBadRequest)
 			return
 		}
+		if rejectOutOfScopeTarget(w, req.IP, "preview") {
+			return
+		}
 
 		// Build SNMP client and perform a full diagnostic walk (no stop keywords)
 		cfg, err := agent.GetSNMPConfig()
@@ -5743,24 +6005,7 @@ func runInteractive(ctx context.Context, configFlag string) {
 
 				// Store the learned OID in device RawData
 				pi := storage.DeviceToPrinterInfo(device)
-				switch strings.ToLower(req.Field) {
-				case "page_count", "total_pages":
-					pi.LearnedOIDs.PageCountOID = foundOID
-				case "mono_pages", "mono_impressions":
-					pi.LearnedOIDs.MonoPagesOID = foundOID
-				case "color_pages", "color_impressions":
-					pi.LearnedOIDs.ColorPagesOID = foundOID
-				case "serial":
-					pi.LearnedOIDs.SerialOID = foundOID
-				case "model":
-					pi.LearnedOIDs.ModelOID = foundOID
-				default:
-					// Store in vendor-specific OIDs
-					if pi.LearnedOIDs.VendorSpecificOIDs == nil {
-						pi.LearnedOIDs.VendorSpecificOIDs = make(map[string]string)
-					}
-					pi.LearnedOIDs.VendorSpecificOIDs[req.Field] = foundOID
-				}
+				setLearnedOID(&pi.LearnedOIDs, req.Field, foundOID)
 				// Update device with learned OIDs
 				if device.RawData == nil {
 					device.RawData = make(map[string]interface{})
@@ -5848,16 +6093,17 @@ func runInteractive(ctx context.Context, configFlag string) {
 	// checkAndFallbackProtocol does a quick TCP connectivity check on the target URL.
 	// If the connection fails, it tries the alternative protocol (https↔http).
 	// This handles cases where web_ui_url is incorrectly set (e.g., HTTPS when device only supports HTTP).
-	checkAndFallbackProtocol := func(ctx context.Context, targetURL, deviceIP, serial string, log *logger.Logger) string {
+	// The second return value is false when neither protocol answered.
+	checkAndFallbackProtocol := func(ctx context.Context, targetURL, deviceIP, serial string, log *logger.Logger) (string, bool) {
 		parsed, err := url.Parse(targetURL)
 		if err != nil {
-			return targetURL
+			return targetURL, true
 		}
 
 		// Determine host:port to check
 		host := parsed.Host
 		if host == "" {
-			return targetURL
+			return targetURL, true
 		}
 
 		// Quick TCP probe with short timeout (don't block the user)
@@ -5868,7 +6114,7 @@ func runInteractive(ctx context.Context, configFlag string) {
 		conn, err := d.DialContext(checkCtx, "tcp", host)
 		if err == nil {
 			conn.Close()
-			return targetURL // Primary URL works
+			return targetURL, true // Primary URL works
 		}
 
 		// Connection failed - try alternative protocol
@@ -5876,28 +6122,28 @@ func runInteractive(ctx context.Context, configFlag string) {
 
 		var altURL string
 		if parsed.Scheme == "https" {
-			// Try HTTP on port 80
-			altURL = "http://" + deviceIP
+			// Try HTTP on port 80 (or the forwarded HTTP port)
+			altURL = "http://" + scanner.HTTPHost(deviceIP)
 		} else {
-			// Try HTTPS on port 443
-			altURL = "https://" + deviceIP
+			// Try HTTPS on port 443 (or the forwarded HTTP port)
+			altURL = "https://" + scanner.HTTPHost(deviceIP)
 		}
 
 		altParsed, err := url.Parse(altURL)
 		if err != nil {
-			return targetURL
+			return targetURL, false
 		}
 
 		altConn, err := d.DialContext(checkCtx, "tcp", altParsed.Host)
 		if err == nil {
 			altConn.Close()
 			log.Info("Proxy: using fallback protocol", "serial", serial, "original", targetURL, "fallback", altURL)
-			return altURL
+			return altURL, true
 		}
 
 		// Both failed - return original and let the proxy handler report the error
 		log.Warn("Proxy: both protocols unreachable", "serial", serial, "primary", targetURL, "fallback", altURL)
-		return targetURL
+		return targetURL, false
 	}
 
 	// Proxy printer web UI - /proxy/<serial>/<path...>
@@ -5989,14 +6235,18 @@ func runInteractive(ctx context.Context, configFlag string) {
 			appLogger.Debug("Proxy: device found", "serial", serial, "ip", device.IP, "manufacturer", device.Manufacturer)
 
 			// Determine target URL (prefer web_ui_url, fallback to http://<ip>)
-			targetURL = device.WebUIURL
-			if targetURL == "" {
-				targetURL = "http://" + device.IP
-			}
+			targetURL = deviceWebUIURL(device)
 
 			// Quick connectivity check with automatic HTTP/HTTPS fallback
 			// This helps when web_ui_url is incorrectly set (common with self-signed HTTPS)
-			targetURL = checkAndFallbackProtocol(ctx, targetURL, device.IP, serial, appLogger)
+			var reachable bool
+			targetURL, reachable = checkAndFallbackProtocol(ctx, targetURL, device.IP, serial, appLogger)
+
+			// Unreachable: the device may have a new IP since the last scan
+			if !reachable && relocator.Relocate(ctx, deviceStore, device) {
+				targetURL = deviceWebUIURL(device)
+				targetURL, _ = checkAndFallbackProtocol(ctx, targetURL, device.IP, serial, appLogger)
+			}
 		}
 
 		target, err := url.Parse(targetURL)
@@ -6027,20 +6277,25 @@ func runInteractive(ctx context.Context, configFlag string) {
 		// Check cache for static resources first
 		if isStaticResource {
 			cacheKey := serial + ":" + targetPath
-			if data, contentType, headers, ok := staticCache.Get(cacheKey); ok {
-				appLogger.Debug("Proxy: serving from cache", "serial", serial, "path", targetPath, "size", len(data))
+			if cached, state := staticCache.Lookup(cacheKey); state != staticCacheMiss {
+				appLogger.Debug("Proxy: serving from cache", "serial", serial, "path", targetPath, "size", len(cached.Data), "stale", state == staticCacheStale)
 				// Copy cached headers
-				for key, values := range headers {
+				for key, values := range cached.Headers {
 					for _, value := range values {
 						w.Header().Add(key, value)
 					}
 				}
-				if contentType != "" {
-					w.Header().Set("Content-Type", contentType)
+				if cached.ContentType != "" {
+					w.Header().Set("Content-Type", cached.ContentType)
 				}
 				w.Header().Set("Cache-Control", "public, max-age=3600") // Browser can cache for 1 hour
+				if state == staticCacheStale {
+					w.Header().Set("X-Cache", "STALE")
+				} else {
+					w.Header().Set("X-Cache", "HIT")
+				}
 				w.WriteHeader(http.StatusOK)
-				w.Write(data)
+				w.Write(cached.Data)
 				return
 			}
 		}
@@ -6347,13 +6602,9 @@ window.top.location.href = '/proxy/%s/';
 		if usbTransport != nil {
 			rproxy.Transport = usbTransport
 		} else {
-			rproxy.Transport = &http.Transport{
-				TLSClientConfig: &tls.Config{
-					// #nosec G402 -- InsecureSkipVerify intentionally enabled:
-					// Network printers commonly use self-signed SSL certificates.
-					// This reverse proxy connects to printer web interfaces on local networks.
-					InsecureSkipVerify: true,
-				},
+			// Per-device TLS overrides (legacy versions, client certs) are
+			// applied when dialing; see devicetls.
+			rproxy.Transport = devicetls.Transport(&http.Transport{
 				MaxIdleConns:          10,
 				IdleConnTimeout:       60 * time.Second,
 				DisableCompression:    false,
@@ -6363,7 +6614,7 @@ window.top.location.href = '/proxy/%s/';
 					Timeout:   15 * time.Second,
 					KeepAlive: 30 * time.Second,
 				}).DialContext,
-			}
+			})
 		}
 
 		appLogger.TraceTag("proxy_request", "Proxy request", "method", r.Method, "path", originalPath, "prefix", proxyPrefix, "target", target.String(), "target_path", targetPath)
@@ -6543,9 +6794,13 @@ window.top.location.href = '/proxy/%s/';
 				body, err := io.ReadAll(resp.Body)
 				if err == nil {
 					resp.Body.Close()
-					// Cache for 15 minutes
+					// USB devices can't be revalidated directly; they just expire
+					origin := ""
+					if usbTransport == nil {
+						origin = strings.TrimRight(target.String(), "/") + targetPath
+					}
 					cacheKey := serial + ":" + targetPath
-					staticCache.Set(cacheKey, body, resp.Header.Get("Content-Type"), resp.Header.Clone(), 15*time.Minute)
+					staticCache.Set(cacheKey, body, resp.Header.Get("Content-Type"), resp.Header.Clone(), origin, staticCacheTTL)
 					appLogger.Debug("Proxy: cached static resource", "serial", serial, "path", targetPath, "size", len(body))
 					// Restore the body for the response
 					resp.Body = io.NopCloser(bytes.NewReader(body))
@@ -6561,6 +6816,8 @@ window.top.location.href = '/proxy/%s/';
 			appLogger.WarnRateLimited("proxy_error_"+serial, 1*time.Minute, "Proxy error", "serial", serial, "error", err.Error())
 			if err == context.DeadlineExceeded || r.Context().Err() == context.DeadlineExceeded {
 				http.Error(w, "Printer did not respond within 45 seconds. The device may be busy, turned off, or its web interface may be disabled.", http.StatusGatewayTimeout)
+			} else if hint := devicetls.Hint(err); hint != "" {
+				http.Error(w, fmt.Sprintf("Proxy connection failed: %v (%s)", err, hint), http.StatusBadGateway)
 			} else {
 				http.Error(w, fmt.Sprintf("Proxy connection failed: %v", err), http.StatusBadGateway)
 			}
@@ -6625,13 +6882,17 @@ window.top.location.href = '/proxy/%s/';
 		_ = json.NewEncoder(w).Encode(out)
 	})
 
-	// Get a merged device profile by serial. /devices/get?serial=SERIAL
+	// Get a merged device profile by serial.
+	// /devices/get?serial=SERIAL[&fields=serial,ip,toner_levels]
+	// fields limits the response to the listed keys; metrics are only read
+	// when page_count or toner_levels is requested.
 	http.HandleFunc("/devices/get", func(w http.ResponseWriter, r *http.Request) {
 		serial := r.URL.Query().Get("serial")
 		if serial == "" {
 			http.Error(w, "serial required", http.StatusBadRequest)
 			return
 		}
+		mask := parseFieldMask(r)
 
 		// Try database first
 		ctx := context.Background()
@@ -6642,13 +6903,15 @@ window.top.location.href = '/proxy/%s/';
 			// Fetch latest metrics for this device
 			var pageCount int
 			var tonerLevels map[string]interface{}
-			if snapshot, err := deviceStore.GetLatestMetrics(ctx, device.Serial); err == nil && snapshot != nil {
-				pageCount = snapshot.PageCount
-				tonerLevels = snapshot.TonerLevels
+			if mask.any("page_count", "toner_levels") {
+				if snapshot, err := deviceStore.GetLatestMetrics(ctx, device.Serial); err == nil && snapshot != nil {
+					pageCount = snapshot.PageCount
+					tonerLevels = snapshot.TonerLevels
+				}
 			}
 
 			// If metrics do not contain toner_levels, try to synthesize from RawData
-			if len(tonerLevels) == 0 && device.RawData != nil {
+			if len(tonerLevels) == 0 && device.RawData != nil && mask.has("toner_levels") {
 				// If raw_data already contains a structured toner_levels map, use it
 				if tl, ok := device.RawData["toner_levels"].(map[string]interface{}); ok && len(tl) > 0 {
 					tonerLevels = tl
@@ -6720,9 +6983,12 @@ window.top.location.href = '/proxy/%s/';
 				// Include RawData if present for extended fields
 				"raw_data": device.RawData,
 			}
+			if rejectUnknownFields(w, mask, func(f string) bool { _, ok := response[f]; return ok }) {
+				return
+			}
 
 			w.Header().Set("Content-Type", "application/json")
-			json.NewEncoder(w).Encode(response)
+			json.NewEncoder(w).Encode(mask.filter(response))
 			return
 		}
 		// Not found in database
@@ -6730,8 +6996,11 @@ window.top.location.href = '/proxy/%s/';
 	})
 
 	// Canonical device profile endpoint (device metadata + latest metrics).
-	// GET /api/devices/profile?serial=SERIAL
+	// GET /api/devices/profile?serial=SERIAL[&fields=serial,ip,toner_levels]
 	// This avoids compatibility/merged fields in legacy /devices/get.
+	// fields may name a section (device, latest_metrics), a qualified field
+	// (latest_metrics.page_count) or a bare field, which is taken from every
+	// section that has it. Sections with no requested fields are left out.
 	http.HandleFunc("/api/devices/profile", func(w http.ResponseWriter, r *http.Request) {
 		if r.Method != http.MethodGet {
 			http.Error(w, "GET only", http.StatusMethodNotAllowed)
@@ -6743,6 +7012,12 @@ window.top.location.href = '/proxy/%s/';
 			http.Error(w, "serial parameter required", http.StatusBadRequest)
 			return
 		}
+		sel := profileSelector{mask: parseFieldMask(r)}
+		if rejectUnknownFields(w, sel.mask, sel.known) {
+			return
+		}
+		deviceFields := sel.selects("device", profileDeviceFields)
+		metricsFields := sel.selects("latest_metrics", profileMetricsFields)
 
 		ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
 		defer cancel()
@@ -6758,18 +7033,42 @@ window.top.location.href = '/proxy/%s/';
 		}
 
 		var snapshot *storage.MetricsSnapshot
-		if s, err := deviceStore.GetLatestMetrics(ctx, serial); err == nil {
-			snapshot = s
-		} else if err != storage.ErrNotFound {
-			http.Error(w, "failed to get latest metrics: "+err.Error(), http.StatusInternalServerError)
+		if metricsFields != nil {
+			if s, err := deviceStore.GetLatestMetrics(ctx, serial); err == nil {
+				snapshot = s
+			} else if err != storage.ErrNotFound {
+				http.Error(w, "failed to get latest metrics: "+err.Error(), http.StatusInternalServerError)
+				return
+			}
+		}
+
+		if sel.mask == nil {
+			w.Header().Set("Content-Type", "application/json")
+			_ = json.NewEncoder(w).Encode(map[string]interface{}{
+				"device":         device,
+				"latest_metrics": snapshot,
+			})
 			return
 		}
 
+		out := map[string]interface{}{}
+		if deviceFields != nil {
+			if out["device"], err = maskStruct(device, deviceFields); err != nil {
+				http.Error(w, "failed to encode device: "+err.Error(), http.StatusInternalServerError)
+				return
+			}
+		}
+		if metricsFields != nil {
+			out["latest_metrics"] = nil
+			if snapshot != nil {
+				if out["latest_metrics"], err = maskStruct(snapshot, metricsFields); err != nil {
+					http.Error(w, "failed to encode metrics: "+err.Error(), http.StatusInternalServerError)
+					return
+				}
+			}
+		}
 		w.Header().Set("Content-Type", "application/json")
-		_ = json.NewEncoder(w).Encode(map[string]interface{}{
-			"device":         device,
-			"latest_metrics": snapshot,
-		})
+		_ = json.NewEncoder(w).Encode(out)
 	})
 
 	// POST /api/devices/initial-page-count - Set initial page count baseline for audit trail
@@ -6825,6 +7124,31 @@ window.top.location.href = '/proxy/%s/';
 		})
 	})
 
+	// Certified meter reads (billing-grade counter snapshots)
+	registerMeterReadHandlers()
+	registerScanRunHandlers()
+
+	// QR asset tags and the mobile asset page
+	registerAssetTagHandlers()
+
+	// Admin SNMP OID browser (ad-hoc GET/walk, learned OID mapping)
+	registerOIDBrowserHandlers()
+	registerDeepScanHandlers()
+	registerPortOverrideHandlers()
+	registerDeviceTLSHandlers()
+	registerSNMPProfileHandlers()
+	registerPluginHandlers()
+	registerIPConflictHandlers()
+	registerDeviceWatchHandlers()
+	registerConfigDriftHandlers()
+	registerLocalAlertHandlers()
+	registerGuestLinkHandlers()
+
+	// GET /metrics/prometheus - device and agent metrics in the Prometheus text format
+	http.HandleFunc("/metrics/prometheus", handlePrometheusMetrics)
+	registerDeviceWritebackHandlers()
+	registerDiscoveryMethodHandlers()
+
 	// GET /api/devices/audit - Get page count audit history for a device
 	http.HandleFunc("/api/devices/audit", func(w http.ResponseWriter, r *http.Request) {
 		if r.Method != http.MethodGet {
@@ -6956,6 +7280,39 @@ window.top.location.href = '/proxy/%s/';
 		})
 	})
 
+	// Merge duplicate device records (IP/MAC-keyed or garbled serials) into
+	// the record with the real serial. POST { dry_run: bool }
+	http.HandleFunc("/devices/reconcile", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "POST only", http.StatusMethodNotAllowed)
+			return
+		}
+		var req struct {
+			DryRun bool `json:"dry_run"`
+		}
+		if r.ContentLength != 0 {
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+				http.Error(w, "bad json", http.StatusBadRequest)
+				return
+			}
+		}
+
+		merges, err := reconcileDuplicateDevices(r.Context(), deviceStore, req.DryRun)
+		if err != nil {
+			appLogger.Error("Device reconciliation failed", "error", err)
+			http.Error(w, "failed to reconcile devices: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if merges == nil {
+			merges = []deviceMerge{}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"dry_run": req.DryRun,
+			"merges":  merges,
+		})
+	})
+
 	// Delete a device profile by serial. POST { serial: "SERIAL" }
 	http.HandleFunc("/devices/delete", func(w http.ResponseWriter, r *http.Request) {
 		if r.Method != http.MethodPost {
@@ -6989,6 +7346,8 @@ window.top.location.href = '/proxy/%s/';
 		if err == nil {
 			deletedFromDB = true
 			appLogger.Info("Deleted device from database", "serial", safeSerial)
+			forgetDeviceAlerts(safeSerial)
+			agentPromStats.forget(safeSerial)
 		} else if err != storage.ErrNotFound {
 			appLogger.Error("Database delete error", "error", err.Error())
 			// Continue to file delete as fallback
@@ -7077,13 +7436,14 @@ window.top.location.href = '/proxy/%s/';
 
 		w.Header().Set("Content-Type", "application/json")
 		json.NewEncoder(w).Encode(map[string]string{
-			"version":    Version,
-			"build_time": BuildTime,
-			"git_commit": GitCommit,
-			"build_type": BuildType,
-			"go_version": runtime.Version(),
-			"os":         runtime.GOOS,
-			"arch":       runtime.GOARCH,
+			"version":             Version,
+			"build_time":          BuildTime,
+			"git_commit":          GitCommit,
+			"build_type":          BuildType,
+			"go_version":          runtime.Version(),
+			"os":                  runtime.GOOS,
+			"arch":                runtime.GOARCH,
+			"runtime_environment": string(agentRuntimeEnv),
 		})
 	})
 
@@ -7452,6 +7812,12 @@ window.top.location.href = '/proxy/%s/';
 		json.NewEncoder(w).Encode(snapshots)
 	})
 
+	// GET /api/v1/devices/{serial}/sparkline - pre-bucketed trends for device cards
+	registerSparklineHandlers()
+
+	// GET /api/devices/metrics/export - full-resolution CSV/Parquet download
+	registerMetricsExportHandlers()
+
 	// POST /api/devices/metrics/delete - delete a single metrics row by id (tier optional)
 	http.HandleFunc("/api/devices/metrics/delete", func(w http.ResponseWriter, r *http.Request) {
 		if r.Method != http.MethodPost {
@@ -7586,6 +7952,9 @@ window.top.location.href = '/proxy/%s/';
 			http.Error(w, "ip required", http.StatusBadRequest)
 			return
 		}
+		if rejectOutOfScopeTarget(w, req.IP, "metrics_collect") {
+			return
+		}
 
 		// Collect metrics snapshot using new scanner
 		metricsCtx, cancelMetrics := context.WithTimeout(r.Context(), 30*time.Second)
@@ -7602,6 +7971,11 @@ window.top.location.href = '/proxy/%s/';
 		// Use new scanner for metrics collection
 		appLogger.Info("Collecting metrics", "serial", req.Serial, "ip", req.IP, "vendor_hint", vendorHint)
 		agentSnapshot, err := CollectMetrics(metricsCtx, req.IP, req.Serial, vendorHint, 10)
+		if err != nil && device != nil && device.IP == req.IP && relocator.Relocate(metricsCtx, deviceStore, device) {
+			// Device answered at its new address; retry there
+			req.IP = device.IP
+			agentSnapshot, err = CollectMetrics(metricsCtx, req.IP, req.Serial, vendorHint, 10)
+		}
 		if err != nil {
 			appLogger.Warn("Metrics collection failed", "serial", req.Serial, "ip", req.IP, "error", err.Error())
 			if agent.DebugEnabled {
@@ -7630,6 +8004,8 @@ window.top.location.href = '/proxy/%s/';
 		storageSnapshot.ScanToHostFlatbed = agentSnapshot.ScanToHostFlatbed
 		storageSnapshot.ScanToHostADF = agentSnapshot.ScanToHostADF
 		storageSnapshot.DuplexSheets = agentSnapshot.DuplexSheets
+		storageSnapshot.SimplexSheets = agentSnapshot.SimplexSheets
+		storageSnapshot.NUpSheets = agentSnapshot.NUpSheets
 		storageSnapshot.JamEvents = agentSnapshot.JamEvents
 		storageSnapshot.ScannerJamEvents = agentSnapshot.ScannerJamEvents
 
@@ -7723,31 +8099,83 @@ window.top.location.href = '/proxy/%s/';
 				"spooler":          snapshot.Spooler,
 				"logging":          snapshot.Logging,
 				"web":              snapshot.Web,
+				"notifications":    snapshot.Notifications,
 				"server_managed":   isServerManaged,
 				"managed_sections": []string{},
+				// Desktop notifications need an interactive session and OS support
+				"desktop_notifications_available": desktopNotifications.available(),
 			}
 			if isServerManaged {
 				// When server-managed, discovery/snmp/features/spooler are locked (logging/web are local)
 				resp["managed_sections"] = []string{"discovery", "snmp", "features", "spooler"}
 			}
+			revs := loadSettingsRevisions(agentConfigStore)
+			resp["revisions"] = revs
 			w.Header().Set("Content-Type", "application/json")
+			w.Header().Set("ETag", settingsETag(revs))
 			json.NewEncoder(w).Encode(resp)
 			return
 
 		case http.MethodPost:
 			var req struct {
-				Discovery map[string]interface{} `json:"discovery"`
-				SNMP      map[string]interface{} `json:"snmp"`
-				Features  map[string]interface{} `json:"features"`
-				Spooler   map[string]interface{} `json:"spooler"`
-				Logging   map[string]interface{} `json:"logging"`
-				Web       map[string]interface{} `json:"web"`
-				Reset     bool                   `json:"reset"`
+				Discovery     map[string]interface{} `json:"discovery"`
+				SNMP          map[string]interface{} `json:"snmp"`
+				Features      map[string]interface{} `json:"features"`
+				Spooler       map[string]interface{} `json:"spooler"`
+				Logging       map[string]interface{} `json:"logging"`
+				Web           map[string]interface{} `json:"web"`
+				Notifications map[string]interface{} `json:"notifications"`
+				Reset         bool                   `json:"reset"`
+				// OverrideSafetyLimits confirms a discovery change that
+				// exceeds the safe scan limits (see discovery_simulation.go)
+				OverrideSafetyLimits bool `json:"override_safety_limits"`
+				// Revisions the edit is based on (see settings_revisions.go)
+				Revisions map[string]int64 `json:"revisions"`
+			}
+			body, err := io.ReadAll(r.Body)
+			if err != nil {
+				http.Error(w, "failed to read body", http.StatusBadRequest)
+				return
 			}
-			if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			if err := json.Unmarshal(body, &req); err != nil {
 				http.Error(w, "invalid json", http.StatusBadRequest)
 				return
 			}
+			if issues, err := validateSettingsBody(body); err != nil {
+				http.Error(w, "invalid json", http.StatusBadRequest)
+				return
+			} else if len(issues) > 0 {
+				writeSettingsValidationErrors(w, issues)
+				return
+			}
+			expected, err := expectedSettingsRevisions(r, req.Revisions)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+
+			settingsWriteMu.Lock()
+			defer settingsWriteMu.Unlock()
+
+			written := settingsSections
+			if !req.Reset {
+				sent := map[string]map[string]interface{}{
+					"discovery": req.Discovery, "snmp": req.SNMP, "features": req.Features, "spooler": req.Spooler,
+					"logging": req.Logging, "web": req.Web, "notifications": req.Notifications,
+				}
+				written = nil
+				for _, section := range settingsSections {
+					if sent[section] != nil {
+						written = append(written, section)
+					}
+				}
+			}
+			revs := loadSettingsRevisions(agentConfigStore)
+			if conflicts := settingsRevisionConflicts(expected, revs, written); len(conflicts) > 0 {
+				writeSettingsConflict(w, conflicts, revs)
+				return
+			}
+			before := loadUnifiedSettings(agentConfigStore)
 
 			if req.Reset {
 				_ = agentConfigStore.SetConfigValue("discovery_settings", map[string]interface{}{})
@@ -7769,8 +8197,12 @@ window.top.location.href = '/proxy/%s/';
 					defaults.Discovery.DetectedSubnet = ipnets[0].String()
 				}
 				applyFeaturesSettingsEffects(&defaults.Features)
+				desktopNotifications.setPreferences(defaults.Notifications)
+				noteLocalSettingsChange(defaults)
+				revs = bumpSettingsRevisions(agentConfigStore, before, defaults)
 				w.Header().Set("Content-Type", "application/json")
-				json.NewEncoder(w).Encode(defaults)
+				w.Header().Set("ETag", settingsETag(revs))
+				json.NewEncoder(w).Encode(settingsWithRevisions(defaults, revs))
 				return
 			}
 
@@ -7779,9 +8211,9 @@ window.top.location.href = '/proxy/%s/';
 			if req.Discovery != nil {
 				updated := current.Discovery
 				mapIntoStruct(req.Discovery, &updated)
-				if _, ok := req.Discovery["ranges_text"]; ok {
-					maxAddrs := 4096
-					res, err := agent.ParseRangeText(updated.RangesText, maxAddrs)
+				_, rangesChanged := req.Discovery["ranges_text"]
+				if rangesChanged {
+					res, err := agent.ParseRangeText(updated.RangesText, discoveryMaxAddresses)
 					if err != nil {
 						http.Error(w, "validation error: "+err.Error(), http.StatusBadRequest)
 						return
@@ -7792,6 +8224,41 @@ window.top.location.href = '/proxy/%s/';
 						_ = json.NewEncoder(w).Encode(res)
 						return
 					}
+				}
+				// The authorized scan scope is fleet policy once server-managed,
+				// and saved ranges must stay inside whichever scope applies
+				scopeChanged := updated.AuthorizedScanScope != current.Discovery.AuthorizedScanScope
+				if scopeChanged && settingsManager != nil && settingsManager.HasManagedSnapshot() {
+					appLogger.Warn("Refused local change to server-managed authorized scan scope")
+					http.Error(w, "authorized scan scope is managed by the server", http.StatusForbidden)
+					return
+				}
+				if rangesChanged || scopeChanged {
+					var rangeIPs []string
+					if res, err := agent.ParseRangeText(updated.RangesText, discoveryMaxAddresses); err == nil {
+						rangeIPs = res.IPs
+					}
+					if issues := validateDiscoveryScanScope(updated, rangeIPs); len(issues) > 0 {
+						writeSettingsValidationErrors(w, issues)
+						return
+					}
+				}
+				// Refuse changes that widen discovery past the safety limits
+				// unless the caller confirmed them after a simulation
+				proposed := current
+				proposed.Discovery = updated
+				pmsettings.Sanitize(&proposed)
+				if sim := simulateDiscoverySettings(current.Discovery, proposed.Discovery, detectedSubnet()); sim.RequiresOverride {
+					if !req.OverrideSafetyLimits {
+						w.Header().Set("Content-Type", "application/json")
+						w.WriteHeader(http.StatusUnprocessableEntity)
+						_ = json.NewEncoder(w).Encode(sim)
+						return
+					}
+					appLogger.Warn("Discovery safety limits overridden", "addresses", sim.TotalAddresses,
+						"estimated_seconds", sim.EstimatedDurationSeconds, "violations", strings.Join(sim.Violations, "; "))
+				}
+				if rangesChanged {
 					if err := agentConfigStore.SetRanges(updated.RangesText); err != nil {
 						http.Error(w, "failed to save ranges: "+err.Error(), http.StatusInternalServerError)
 						return
@@ -7862,6 +8329,14 @@ window.top.location.href = '/proxy/%s/';
 				current.Web = updated
 			}
 
+			if req.Notifications != nil {
+				updated := current.Notifications
+				mapIntoStruct(req.Notifications, &updated)
+				envelope["notifications"] = structToMap(updated)
+				current.Notifications = updated
+				desktopNotifications.setPreferences(updated)
+			}
+
 			if err := agentConfigStore.SetConfigValue("settings", envelope); err != nil {
 				http.Error(w, "failed to save settings: "+err.Error(), http.StatusInternalServerError)
 				return
@@ -7869,16 +8344,22 @@ window.top.location.href = '/proxy/%s/';
 
 			pmsettings.Sanitize(&current)
 			applyFeaturesSettingsEffects(&current.Features)
+			noteLocalSettingsChange(current)
+			revs = bumpSettingsRevisions(agentConfigStore, before, current)
 			w.Header().Set("Content-Type", "application/json")
-			json.NewEncoder(w).Encode(current)
+			w.Header().Set("ETag", settingsETag(revs))
+			json.NewEncoder(w).Encode(settingsWithRevisions(current, revs))
 			return
 		}
 
 		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
 	})
 
+	// JSON Schema of the /settings document (see settings_schema.go)
+	http.HandleFunc("/settings/schema", handleSettingsSchema)
+
 	http.HandleFunc("/settings/server", func(w http.ResponseWriter, r *http.Request) {
-		dataDir, err := config.GetDataDirectory("agent", isService)
+		dataDir, err := agentDataDirectory()
 		if err != nil {
 			http.Error(w, "failed to determine data directory", http.StatusInternalServerError)
 			return
@@ -7908,6 +8389,32 @@ window.top.location.href = '/proxy/%s/';
 		}
 	})
 
+	// Retry the server connection now instead of waiting out the circuit
+	// breaker's pause.
+	http.HandleFunc("/settings/server/retry", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		uploadWorkerMu.RLock()
+		worker := uploadWorker
+		uploadWorkerMu.RUnlock()
+		if worker == nil {
+			http.Error(w, "not connected to a server", http.StatusConflict)
+			return
+		}
+		worker.RetryNow()
+		if appLogger != nil {
+			appLogger.Info("Server connection retry requested")
+		}
+		dataDir, _ := agentDataDirectory()
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": true,
+			"status":  snapshotServerConnectionStatus(agentConfig, dataDir),
+		})
+	})
+
 	// Join the central server using a join token issued by the server.
 	// Body: {"server_url":"https://central:9443","token":"<raw join token>","ca_path":"/path/to/ca.pem","insecure":false}
 	http.HandleFunc("/settings/probe-server", func(w http.ResponseWriter, r *http.Request) {
@@ -8028,7 +8535,7 @@ window.top.location.href = '/proxy/%s/';
 				return
 			}
 		}
-		dataDir, err := config.GetDataDirectory("agent", isService)
+		dataDir, err := agentDataDirectory()
 		if err != nil {
 			w.WriteHeader(http.StatusInternalServerError)
 			w.Write([]byte(`{"error":"failed to determine data directory"}`))
@@ -8111,7 +8618,7 @@ window.top.location.href = '/proxy/%s/';
 				return
 			}
 		}
-		dataDir, err := config.GetDataDirectory("agent", isService)
+		dataDir, err := agentDataDirectory()
 		if err != nil {
 			w.WriteHeader(http.StatusInternalServerError)
 			w.Write([]byte(`{"error":"failed to determine data directory"}`))
@@ -8156,6 +8663,8 @@ window.top.location.href = '/proxy/%s/';
 	})
 
 	// Legacy subnet scan endpoint (deprecated, use /settings/discovery)
+	http.HandleFunc("/settings/discovery/simulate", handleDiscoverySimulate)
+
 	http.HandleFunc("/settings/subnet_scan", func(w http.ResponseWriter, r *http.Request) {
 		if r.Method == "GET" {
 			w.Header().Set("Content-Type", "application/json")
@@ -8353,6 +8862,9 @@ window.top.location.href = '/proxy/%s/';
 	// The handler is set globally so it's available even if upload worker starts later
 	setLocalProxyHandler(rootHandler)
 
+	// Network listeners must not be able to pose as the server proxy
+	networkHandler := stripServerProxyHeaders(rootHandler)
+
 	// Create server instances for graceful shutdown
 	var httpServer *http.Server
 	var httpsServer *http.Server
@@ -8382,7 +8894,7 @@ window.top.location.href = '/proxy/%s/';
 			appLogger.Info("HTTP server will redirect to HTTPS", "httpPort", httpPort, "httpsPort", httpsPort)
 		} else {
 			// Use default handler (http.DefaultServeMux with all registered routes)
-			httpHandler = rootHandler
+			httpHandler = networkHandler
 		}
 
 		httpServer = &http.Server{
@@ -8417,7 +8929,7 @@ window.top.location.href = '/proxy/%s/';
 			}
 
 			httpsServer = &http.Server{
-				Handler:           rootHandler,
+				Handler:           networkHandler,
 				ReadTimeout:       30 * time.Second,
 				ReadHeaderTimeout: 10 * time.Second,
 				WriteTimeout:      120 * time.Second, // USB proxy can be very slow (5-10s per page)
@@ -8451,6 +8963,16 @@ window.top.location.href = '/proxy/%s/';
 		}
 	}
 
+	// Advertise the web UI on the LAN (opt-in via [mdns])
+	advertiseHTTP, advertiseHTTPS := "", ""
+	if enableHTTP {
+		advertiseHTTP = httpPort
+	}
+	if enableHTTPS {
+		advertiseHTTPS = httpsPort
+	}
+	startMDNSAdvertiser(ctx, agentConfig, dataDir, advertiseHTTP, advertiseHTTPS)
+
 	// Wait for shutdown signal
 	<-ctx.Done()
 	appLogger.Info("Shutdown signal received, stopping servers...")
@@ -8488,5 +9010,6 @@ window.top.location.href = '/proxy/%s/';
 
 	// Wait for servers to finish
 	wg.Wait()
+	saveStaticCache(dataDir)
 	appLogger.Info("All servers stopped")
 }
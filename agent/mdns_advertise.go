@@ -0,0 +1,45 @@
+package main
+
+import (
+	"context"
+	"os"
+	"strconv"
+	"strings"
+
+	"printmaster/agent/agent"
+)
+
+// startMDNSAdvertiser publishes the agent web UI over mDNS when [mdns]
+// advertise is set. Failures are logged; the agent runs without it.
+func startMDNSAdvertiser(ctx context.Context, cfg *AgentConfig, dataDir string, httpPort, httpsPort string) {
+	if cfg == nil || !cfg.MDNS.Advertise {
+		return
+	}
+	agentID := cfg.Server.AgentID
+	if agentID == "" {
+		if id, err := LoadOrGenerateAgentID(dataDir); err == nil {
+			agentID = id
+		}
+	}
+	hostname, _ := os.Hostname()
+	ad := mdnsAdvertisement(cfg.MDNS, agentID, hostname, httpPort, httpsPort)
+	if err := agent.StartMDNSAdvertiser(ctx, ad); err != nil {
+		appLogger.Warn("mDNS advertising disabled", "error", err)
+	}
+}
+
+// mdnsAdvertisement builds the advertisement for the enabled listeners; an
+// empty port means that listener is off.
+func mdnsAdvertisement(cfg MDNSConfig, agentID, hostname, httpPort, httpsPort string) agent.MDNSAdvertisement {
+	instance := strings.TrimSpace(cfg.InstanceName)
+	if instance == "" {
+		instance = strings.TrimSuffix(hostname, ".local")
+	}
+	if instance == "" {
+		instance = "printmaster-agent"
+	}
+	ad := agent.MDNSAdvertisement{Instance: instance, AgentID: agentID, Version: Version}
+	ad.HTTPPort, _ = strconv.Atoi(httpPort)
+	ad.HTTPSPort, _ = strconv.Atoi(httpsPort)
+	return ad
+}
@@ -0,0 +1,33 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestMDNSAdvertisement(t *testing.T) {
+	t.Parallel()
+	ad := mdnsAdvertisement(MDNSConfig{Advertise: true}, "agent-1", "frontdesk.local", "8080", "8443")
+	if ad.Instance != "frontdesk" || ad.Port() != 8443 {
+		t.Fatalf("instance/port = %q/%d, want frontdesk/8443", ad.Instance, ad.Port())
+	}
+	want := []string{"path=/", "agent_id=agent-1", "version=" + Version, "https_port=8443", "http_port=8080"}
+	if got := ad.TXT(); !reflect.DeepEqual(got, want) {
+		t.Errorf("TXT = %v, want %v", got, want)
+	}
+
+	// HTTPS off: the service points at HTTP and omits https_port
+	ad = mdnsAdvertisement(MDNSConfig{InstanceName: " Lobby Agent "}, "", "", "8080", "")
+	if ad.Instance != "Lobby Agent" || ad.Port() != 8080 {
+		t.Errorf("instance/port = %q/%d, want Lobby Agent/8080", ad.Instance, ad.Port())
+	}
+	for _, rec := range ad.TXT() {
+		if rec == "https_port=0" || rec == "agent_id=" {
+			t.Errorf("unexpected TXT record %q", rec)
+		}
+	}
+
+	if ad := mdnsAdvertisement(MDNSConfig{}, "", "", "", ""); ad.Instance != "printmaster-agent" || ad.Port() != 0 {
+		t.Errorf("fallback = %+v", ad)
+	}
+}
@@ -0,0 +1,381 @@
+package main
+
+import (
+	"context"
+	"crypto/ed25519"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"printmaster/agent/agent"
+	"printmaster/agent/scanner"
+	"printmaster/agent/storage"
+	"printmaster/common/meterread"
+
+	"github.com/google/uuid"
+	"github.com/gosnmp/gosnmp"
+)
+
+// OIDs read back from the device to confirm its identity at meter read time.
+const (
+	oidSysDescr      = "1.3.6.1.2.1.1.1.0"
+	oidSysObjectID   = "1.3.6.1.2.1.1.2.0"
+	oidSysUpTime     = "1.3.6.1.2.1.1.3.0"
+	oidPrinterSerial = "1.3.6.1.2.1.43.5.1.1.17.1" // prtGeneralSerialNumber
+)
+
+// errMeterReadUnsigned is returned when the agent is not registered with a
+// server or has no signing key. Certified reads are only useful to a server,
+// so none are captured.
+var errMeterReadUnsigned = errors.New("agent is not registered with a server; meter reads cannot be signed")
+
+var (
+	meterReadEnabled atomic.Bool
+	meterReadDay     atomic.Int32
+	meterReadKey     atomic.Pointer[ed25519.PrivateKey]
+)
+
+func init() {
+	meterReadDay.Store(1)
+}
+
+// applyMeterReadSettings updates the monthly meter read policy from a
+// discovery settings map. Missing keys leave the current values untouched.
+func applyMeterReadSettings(disc map[string]interface{}) {
+	if v, ok := disc["meter_read_enabled"].(bool); ok {
+		if meterReadEnabled.Swap(v) != v && appLogger != nil {
+			appLogger.Info("Meter read setting changed", "enabled", v)
+		}
+	}
+	if v, ok := disc["meter_read_day_of_month"].(float64); ok && v >= 1 && v <= 28 {
+		meterReadDay.Store(int32(v))
+	}
+}
+
+// meterReadStore returns the meter read store backing deviceStore, if any.
+func meterReadStore() storage.MeterReadStore {
+	store, _ := deviceStore.(storage.MeterReadStore)
+	return store
+}
+
+// loadMeterReadKey loads (or creates) the agent's meter read signing key and
+// announces its public half through client.
+func loadMeterReadKey(dataDir string, client *agent.ServerClient) error {
+	key, err := LoadOrGenerateMeterReadKey(dataDir)
+	if err != nil {
+		return err
+	}
+	meterReadKey.Store(&key)
+	client.SetMeterReadPublicKey(meterread.EncodePublicKey(key.Public().(ed25519.PublicKey)))
+	return nil
+}
+
+// meterReadSigner returns the agent ID and the key reads are signed with.
+// Reads are only signed once the agent is registered with a server.
+func meterReadSigner() (string, ed25519.PrivateKey, bool) {
+	uploadWorkerMu.RLock()
+	w := uploadWorker
+	uploadWorkerMu.RUnlock()
+	client := w.Client()
+	key := meterReadKey.Load()
+	if client == nil || key == nil || client.GetToken() == "" {
+		return "", nil, false
+	}
+	return client.AgentID, *key, true
+}
+
+// startMeterReadScheduler checks hourly whether saved devices are due for
+// their monthly certified meter read. Devices that are unreachable on the
+// read day are retried on later checks until a read for the month succeeds.
+func startMeterReadScheduler(ctx context.Context) {
+	go func() {
+		// Give discovery and server registration a head start
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(2 * time.Minute):
+		}
+		ticker := time.NewTicker(time.Hour)
+		defer ticker.Stop()
+		for {
+			runScheduledMeterReads(ctx)
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// runScheduledMeterReads captures a read for every saved device whose monthly
+// read is due.
+func runScheduledMeterReads(ctx context.Context) {
+	if !meterReadEnabled.Load() || deviceStore == nil {
+		return
+	}
+	defer watchdogBegin("meter_reads")()
+	store := meterReadStore()
+	if store == nil {
+		return
+	}
+	if _, _, ok := meterReadSigner(); !ok {
+		appLogger.Debug("Meter reads due but agent is not registered with a server")
+		return
+	}
+
+	saved := true
+	devices, err := deviceStore.List(ctx, storage.DeviceFilter{IsSaved: &saved})
+	if err != nil {
+		appLogger.Warn("Meter read: failed to list devices", "error", err)
+		return
+	}
+	now := time.Now()
+	day := int(meterReadDay.Load())
+	captured := 0
+	for _, device := range devices {
+		if ctx.Err() != nil {
+			return
+		}
+		if device.IP == "" || device.IsUSB {
+			continue
+		}
+		last, err := store.LatestMeterReadPeriod(ctx, device.Serial, meterread.TriggerScheduled)
+		if err != nil || !meterread.Due(now, day, last) {
+			continue
+		}
+		if _, err := captureMeterRead(ctx, device, meterread.TriggerScheduled); err != nil {
+			appLogger.WarnRateLimited("meter_read_"+device.Serial, time.Hour, "Meter read failed", "serial", device.Serial, "ip", device.IP, "error", err)
+			continue
+		}
+		captured++
+	}
+	if captured > 0 {
+		appLogger.Info("Monthly meter reads captured", "count", captured, "period", meterread.PeriodOf(now))
+	}
+}
+
+// captureMeterRead reads the device's counters, confirms its identity,
+// signs the result and stores it for upload.
+func captureMeterRead(ctx context.Context, device *storage.Device, trigger string) (*storage.MeterRead, error) {
+	store := meterReadStore()
+	if store == nil {
+		return nil, fmt.Errorf("meter reads are not supported by this store")
+	}
+	agentID, key, ok := meterReadSigner()
+	if !ok {
+		return nil, errMeterReadUnsigned
+	}
+
+	pi := storage.DeviceToPrinterInfo(device)
+	confirmation, err := confirmMeterReadDevice(ctx, device, pi.LearnedOIDs.SerialOID)
+	if err != nil {
+		return nil, fmt.Errorf("device confirmation failed: %w", err)
+	}
+	snapshot, pdus, err := collectMetricsWithPDUs(ctx, device.IP, device.Serial, device.Manufacturer, 10, &pi.LearnedOIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	read := newMeterRead(device, agentID, trigger, time.Now(), snapshot, pdus, confirmation)
+	read.Sign(key)
+	if err := store.AddMeterRead(ctx, read); err != nil {
+		return nil, err
+	}
+	if !confirmation.SerialMatch {
+		appLogger.Warn("Meter read captured but device serial could not be confirmed",
+			"serial", device.Serial, "reported_serial", confirmation.ReportedSerial, "ip", device.IP)
+	}
+	return read, nil
+}
+
+// newMeterRead assembles an unsigned meter read from collected data.
+func newMeterRead(device *storage.Device, agentID, trigger string, now time.Time, snapshot *agent.DeviceMetricsSnapshot, pdus []gosnmp.SnmpPDU, confirmation meterread.Confirmation) *storage.MeterRead {
+	now = now.UTC()
+	read := &storage.MeterRead{
+		ID:           uuid.NewString(),
+		Serial:       device.Serial,
+		AgentID:      agentID,
+		IP:           device.IP,
+		Manufacturer: device.Manufacturer,
+		Model:        device.Model,
+		Period:       meterread.PeriodOf(now),
+		Trigger:      trigger,
+		ReadAt:       now,
+		Confirmation: confirmation,
+		RawOIDs:      make([]meterread.OIDValue, 0, len(pdus)),
+	}
+	if snapshot != nil {
+		read.PageCount = int64(snapshot.PageCount)
+		read.MonoPages = int64(snapshot.MonoPages)
+		read.ColorPages = int64(snapshot.ColorPages)
+		read.ScanCount = int64(snapshot.ScanCount)
+	}
+	for _, pdu := range pdus {
+		if pdu.Type == gosnmp.NoSuchObject || pdu.Type == gosnmp.NoSuchInstance || pdu.Type == gosnmp.EndOfMibView {
+			continue
+		}
+		read.RawOIDs = append(read.RawOIDs, meterread.OIDValue{
+			OID:   strings.TrimPrefix(pdu.Name, "."),
+			Type:  pdu.Type.String(),
+			Value: pduString(pdu),
+		})
+	}
+	return read
+}
+
+// confirmMeterReadDevice reads identity OIDs from the device so the meter read
+// records which physical device answered.
+func confirmMeterReadDevice(ctx context.Context, device *storage.Device, serialOID string) (meterread.Confirmation, error) {
+	conf := meterread.Confirmation{MACAddress: device.MACAddress}
+	cfg, err := scanner.GetSNMPConfig()
+	if err != nil {
+		return conf, err
+	}
+	client, err := scanner.NewSNMPClient(cfg, device.IP, 5)
+	if err != nil {
+		return conf, err
+	}
+	defer client.Close()
+
+	oids := []string{oidSysDescr, oidSysObjectID, oidSysUpTime, oidPrinterSerial}
+	serialOID = strings.TrimPrefix(serialOID, ".")
+	if serialOID != "" && serialOID != oidPrinterSerial {
+		oids = append(oids, serialOID)
+	}
+	pkt, err := client.Get(oids)
+	if err != nil {
+		return conf, err
+	}
+	if pkt == nil {
+		return conf, fmt.Errorf("no response from %s", device.IP)
+	}
+	return confirmationFromPDUs(conf, pkt.Variables, device.Serial, serialOID), nil
+}
+
+// confirmationFromPDUs fills identity fields from SNMP values. A learned
+// vendor serial OID takes precedence over the standard printer MIB serial.
+func confirmationFromPDUs(conf meterread.Confirmation, pdus []gosnmp.SnmpPDU, expectedSerial, serialOID string) meterread.Confirmation {
+	var stdSerial, vendorSerial string
+	for _, pdu := range pdus {
+		if pdu.Type == gosnmp.NoSuchObject || pdu.Type == gosnmp.NoSuchInstance {
+			continue
+		}
+		switch strings.TrimPrefix(pdu.Name, ".") {
+		case oidSysDescr:
+			conf.SysDescr = pduString(pdu)
+		case oidSysObjectID:
+			conf.SysObjectID = strings.TrimPrefix(pduString(pdu), ".")
+		case oidSysUpTime:
+			conf.SysUpTime = gosnmp.ToBigInt(pdu.Value).Int64()
+		case oidPrinterSerial:
+			stdSerial = strings.TrimSpace(pduString(pdu))
+		case serialOID:
+			vendorSerial = strings.TrimSpace(pduString(pdu))
+		}
+	}
+	conf.ReportedSerial = stdSerial
+	if vendorSerial != "" {
+		conf.ReportedSerial = vendorSerial
+	}
+	conf.SerialMatch = conf.ReportedSerial != "" && strings.EqualFold(conf.ReportedSerial, strings.TrimSpace(expectedSerial))
+	return conf
+}
+
+func pduString(pdu gosnmp.SnmpPDU) string {
+	switch v := pdu.Value.(type) {
+	case []byte:
+		return string(v)
+	case string:
+		return v
+	case nil:
+		return ""
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// registerMeterReadHandlers exposes certified meter reads on the local API.
+func registerMeterReadHandlers() {
+	// GET /api/meter-reads?serial=&limit= - List certified meter reads, newest first
+	http.HandleFunc("/api/meter-reads", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "GET only", http.StatusMethodNotAllowed)
+			return
+		}
+		store := meterReadStore()
+		if store == nil {
+			http.Error(w, "meter reads not supported", http.StatusNotImplemented)
+			return
+		}
+		limit := 100
+		if l := r.URL.Query().Get("limit"); l != "" {
+			if parsed, err := strconv.Atoi(l); err == nil && parsed > 0 {
+				limit = parsed
+			}
+		}
+		serial := r.URL.Query().Get("serial")
+
+		ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+		defer cancel()
+		reads, err := store.ListMeterReads(ctx, serial, limit)
+		if err != nil {
+			appLogger.Error("Failed to list meter reads", "serial", serial, "error", err)
+			http.Error(w, "failed to list meter reads: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if reads == nil {
+			reads = []*storage.MeterRead{}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"reads": reads,
+			"count": len(reads),
+		})
+	})
+
+	// POST /api/meter-reads/capture - Take an on-demand certified meter read of a device
+	http.HandleFunc("/api/meter-reads/capture", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "POST only", http.StatusMethodNotAllowed)
+			return
+		}
+		var req struct {
+			Serial string `json:"serial"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "bad json", http.StatusBadRequest)
+			return
+		}
+		if req.Serial == "" {
+			http.Error(w, "serial required", http.StatusBadRequest)
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(r.Context(), 60*time.Second)
+		defer cancel()
+		device, err := deviceStore.Get(ctx, req.Serial)
+		if err != nil {
+			http.Error(w, "device not found", http.StatusNotFound)
+			return
+		}
+		read, err := captureMeterRead(ctx, device, meterread.TriggerManual)
+		if errors.Is(err, errMeterReadUnsigned) {
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		}
+		if err != nil {
+			appLogger.Warn("Manual meter read failed", "serial", req.Serial, "error", err)
+			http.Error(w, "meter read failed: "+err.Error(), http.StatusBadGateway)
+			return
+		}
+		appLogger.Info("Manual meter read captured", "serial", read.Serial, "page_count", read.PageCount)
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(read)
+	})
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"printmaster/agent/agent"
+	"printmaster/agent/storage"
+	"printmaster/common/meterread"
+
+	"github.com/gosnmp/gosnmp"
+)
+
+func TestConfirmationFromPDUs(t *testing.T) {
+	t.Parallel()
+	pdus := []gosnmp.SnmpPDU{
+		{Name: "." + oidSysDescr, Type: gosnmp.OctetString, Value: []byte("HP LaserJet M507")},
+		{Name: "." + oidSysObjectID, Type: gosnmp.ObjectIdentifier, Value: ".1.3.6.1.4.1.11.2.3.9.1"},
+		{Name: "." + oidSysUpTime, Type: gosnmp.TimeTicks, Value: uint32(4242)},
+		{Name: "." + oidPrinterSerial, Type: gosnmp.OctetString, Value: []byte(" phbbb12345 ")},
+	}
+
+	conf := confirmationFromPDUs(meterread.Confirmation{}, pdus, "PHBBB12345", "")
+	if !conf.SerialMatch || conf.ReportedSerial != "phbbb12345" {
+		t.Errorf("serial not confirmed: %+v", conf)
+	}
+	if conf.SysObjectID != "1.3.6.1.4.1.11.2.3.9.1" || conf.SysUpTime != 4242 || conf.SysDescr != "HP LaserJet M507" {
+		t.Errorf("identity fields not captured: %+v", conf)
+	}
+
+	// A learned vendor serial OID wins over the printer MIB serial
+	vendorOID := "1.3.6.1.4.1.11.2.3.9.4.2.1.1.3.3.0"
+	pdus = append(pdus, gosnmp.SnmpPDU{Name: "." + vendorOID, Type: gosnmp.OctetString, Value: []byte("OTHER")})
+	conf = confirmationFromPDUs(meterread.Confirmation{}, pdus, "PHBBB12345", vendorOID)
+	if conf.SerialMatch || conf.ReportedSerial != "OTHER" {
+		t.Errorf("expected mismatch from vendor serial, got %+v", conf)
+	}
+}
+
+func TestNewMeterRead(t *testing.T) {
+	t.Parallel()
+	device := &storage.Device{}
+	device.Serial = "SN1"
+	device.IP = "10.0.0.9"
+	device.Manufacturer = "HP"
+	snapshot := &agent.DeviceMetricsSnapshot{Serial: "SN1", PageCount: 1500, MonoPages: 1200, ColorPages: 300}
+	pdus := []gosnmp.SnmpPDU{
+		{Name: ".1.3.6.1.2.1.43.10.2.1.4.1.1", Type: gosnmp.Counter32, Value: uint(1500)},
+		{Name: ".1.3.6.1.4.1.11.2.3.9.4.2.1.4.1.2.7.0", Type: gosnmp.NoSuchObject},
+	}
+	now := time.Date(2026, 10, 1, 3, 0, 0, 0, time.FixedZone("X", 3600))
+
+	read := newMeterRead(device, "agent-1", meterread.TriggerScheduled, now, snapshot, pdus, meterread.Confirmation{SerialMatch: true})
+	if read.ID == "" || read.Period != "2026-10" || read.ReadAt.Location() != time.UTC {
+		t.Errorf("unexpected read metadata: %+v", read)
+	}
+	if read.PageCount != 1500 || read.MonoPages != 1200 || read.ColorPages != 300 {
+		t.Errorf("counters not copied: %+v", read)
+	}
+	if len(read.RawOIDs) != 1 || read.RawOIDs[0].OID != "1.3.6.1.2.1.43.10.2.1.4.1.1" || read.RawOIDs[0].Value != "1500" {
+		t.Errorf("raw OIDs = %+v", read.RawOIDs)
+	}
+}
@@ -0,0 +1,273 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"printmaster/agent/agent"
+	"printmaster/agent/storage"
+	"printmaster/common/parquet"
+)
+
+// metricsExportBaseColumns are the fixed columns of a metrics export, in
+// output order. "toner" expands to one toner_<supply> column per supply.
+var metricsExportBaseColumns = []string{"timestamp", "tier", "page_count", "color_pages", "mono_pages", "scan_count", "toner"}
+
+var unsafeFilenameChars = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
+// metricsExportColumns resolves the requested column list against the toner
+// supplies present in the data. An empty request selects every column.
+func metricsExportColumns(requested string, snapshots []*storage.MetricsSnapshot) ([]string, error) {
+	supplySet := map[string]bool{}
+	for _, snap := range snapshots {
+		for key := range snap.TonerLevels {
+			supplySet[key] = true
+		}
+	}
+	supplies := make([]string, 0, len(supplySet))
+	for key := range supplySet {
+		supplies = append(supplies, key)
+	}
+	sort.Strings(supplies)
+
+	names := metricsExportBaseColumns
+	if strings.TrimSpace(requested) != "" {
+		names = strings.Split(requested, ",")
+	}
+	var cols []string
+	seen := map[string]bool{}
+	add := func(name string) {
+		if !seen[name] {
+			seen[name] = true
+			cols = append(cols, name)
+		}
+	}
+	for _, raw := range names {
+		name := strings.TrimSpace(raw)
+		switch {
+		case name == "":
+			continue
+		case name == "toner":
+			for _, supply := range supplies {
+				add("toner_" + supply)
+			}
+		case strings.HasPrefix(name, "toner_"):
+			add(name)
+		case name == "timestamp" || name == "tier" || name == "page_count" || name == "color_pages" || name == "mono_pages" || name == "scan_count":
+			add(name)
+		default:
+			return nil, fmt.Errorf("unknown column %q", name)
+		}
+	}
+	if len(cols) == 0 {
+		return nil, fmt.Errorf("no columns selected")
+	}
+	return cols, nil
+}
+
+// metricsExportValue returns the value of one column for a snapshot; nil
+// means no value (missing or unknown toner level).
+func metricsExportValue(snap *storage.MetricsSnapshot, col string) any {
+	switch col {
+	case "timestamp":
+		return snap.Timestamp.UTC()
+	case "tier":
+		return snap.Tier
+	case "page_count":
+		return int64(snap.PageCount)
+	case "color_pages":
+		return int64(snap.ColorPages)
+	case "mono_pages":
+		return int64(snap.MonoPages)
+	case "scan_count":
+		return int64(snap.ScanCount)
+	}
+	supply := strings.TrimPrefix(col, "toner_")
+	raw, ok := snap.TonerLevels[supply]
+	if !ok {
+		return nil
+	}
+	var f float64
+	switch n := raw.(type) {
+	case float64:
+		f = n
+	case int:
+		f = float64(n)
+	case int64:
+		f = float64(n)
+	default:
+		return nil
+	}
+	// Negative values are SNMP sentinels (unknown / some remaining)
+	if f < 0 || math.IsNaN(f) {
+		return nil
+	}
+	return f
+}
+
+func metricsExportParquetType(col string) parquet.Type {
+	switch {
+	case col == "timestamp":
+		return parquet.Timestamp
+	case col == "tier":
+		return parquet.String
+	case strings.HasPrefix(col, "toner_"):
+		return parquet.Double
+	default:
+		return parquet.Int64
+	}
+}
+
+// writeMetricsCSV streams snapshots as CSV with a header row.
+func writeMetricsCSV(w io.Writer, cols []string, snapshots []*storage.MetricsSnapshot) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write(cols); err != nil {
+		return err
+	}
+	flusher, _ := w.(http.Flusher)
+	record := make([]string, len(cols))
+	for i, snap := range snapshots {
+		for j, col := range cols {
+			switch v := metricsExportValue(snap, col).(type) {
+			case nil:
+				record[j] = ""
+			case time.Time:
+				record[j] = v.Format(time.RFC3339)
+			case int64:
+				record[j] = strconv.FormatInt(v, 10)
+			case float64:
+				record[j] = strconv.FormatFloat(v, 'f', -1, 64)
+			case string:
+				record[j] = v
+			}
+		}
+		if err := cw.Write(record); err != nil {
+			return err
+		}
+		if i%1000 == 999 {
+			cw.Flush()
+			if flusher != nil {
+				flusher.Flush()
+			}
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// writeMetricsParquet streams snapshots as a Parquet file.
+func writeMetricsParquet(w io.Writer, cols []string, snapshots []*storage.MetricsSnapshot) error {
+	schema := make([]parquet.Column, len(cols))
+	for i, col := range cols {
+		schema[i] = parquet.Column{Name: col, Type: metricsExportParquetType(col)}
+	}
+	pw := parquet.NewWriter(w, schema)
+	pw.CreatedBy = "printmaster-agent " + Version
+	row := make([]any, len(cols))
+	for _, snap := range snapshots {
+		for j, col := range cols {
+			row[j] = metricsExportValue(snap, col)
+		}
+		if err := pw.Write(row); err != nil {
+			return err
+		}
+	}
+	return pw.Close()
+}
+
+// parseExportTime accepts RFC3339 timestamps or plain dates (local midnight).
+func parseExportTime(value string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	return time.ParseInLocation("2006-01-02", value, time.Local)
+}
+
+// registerMetricsExportHandlers exposes full-resolution metrics exports so a
+// device's complete tiered history can be downloaded without downsampling.
+func registerMetricsExportHandlers() {
+	// GET /api/devices/metrics/export?serial=X&since=&until=&format=csv|parquet&columns=
+	http.HandleFunc("/api/devices/metrics/export", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "GET only", http.StatusMethodNotAllowed)
+			return
+		}
+		q := r.URL.Query()
+		serial := q.Get("serial")
+		if serial == "" {
+			http.Error(w, "serial parameter required", http.StatusBadRequest)
+			return
+		}
+		format := strings.ToLower(q.Get("format"))
+		if format == "" {
+			format = "csv"
+		}
+		if format != "csv" && format != "parquet" {
+			http.Error(w, "format must be csv or parquet", http.StatusBadRequest)
+			return
+		}
+
+		// Default to the complete history
+		var since time.Time
+		until := time.Now()
+		if v := q.Get("since"); v != "" {
+			t, err := parseExportTime(v)
+			if err != nil {
+				http.Error(w, "invalid since parameter (use RFC3339 or YYYY-MM-DD)", http.StatusBadRequest)
+				return
+			}
+			since = t
+		}
+		if v := q.Get("until"); v != "" {
+			t, err := parseExportTime(v)
+			if err != nil {
+				http.Error(w, "invalid until parameter (use RFC3339 or YYYY-MM-DD)", http.StatusBadRequest)
+				return
+			}
+			until = t
+		}
+		if until.Before(since) {
+			http.Error(w, "until must not be before since", http.StatusBadRequest)
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(r.Context(), 60*time.Second)
+		defer cancel()
+		snapshots, err := deviceStore.GetTieredMetricsHistory(ctx, serial, since, until)
+		if err != nil {
+			agent.Error(fmt.Sprintf("Failed to export metrics: serial=%s error=%v", serial, err))
+			http.Error(w, "failed to get metrics history: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		cols, err := metricsExportColumns(q.Get("columns"), snapshots)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		filename := fmt.Sprintf("metrics-%s-%s.%s", unsafeFilenameChars.ReplaceAllString(serial, "_"), time.Now().Format("20060102"), format)
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+		w.Header().Set("Cache-Control", "no-store")
+		if format == "parquet" {
+			w.Header().Set("Content-Type", "application/vnd.apache.parquet")
+			err = writeMetricsParquet(w, cols, snapshots)
+		} else {
+			w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+			err = writeMetricsCSV(w, cols, snapshots)
+		}
+		if err != nil {
+			// Headers are already sent; the client sees a truncated file.
+			agent.Warn(fmt.Sprintf("Metrics export interrupted: serial=%s format=%s error=%v", serial, format, err))
+		}
+	})
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"printmaster/agent/storage"
+)
+
+func TestMetricsExportColumns(t *testing.T) {
+	t.Parallel()
+	snapshots := []*storage.MetricsSnapshot{
+		sparkSnap(time.Now(), 10, map[string]interface{}{"cyan": 50.0}),
+		sparkSnap(time.Now(), 20, map[string]interface{}{"black": 40.0}),
+	}
+
+	cols, err := metricsExportColumns("", snapshots)
+	if err != nil {
+		t.Fatalf("metricsExportColumns: %v", err)
+	}
+	want := "timestamp,tier,page_count,color_pages,mono_pages,scan_count,toner_black,toner_cyan"
+	if got := strings.Join(cols, ","); got != want {
+		t.Fatalf("default columns = %s, want %s", got, want)
+	}
+
+	cols, err = metricsExportColumns("timestamp, page_count,toner_black,page_count", snapshots)
+	if err != nil || strings.Join(cols, ",") != "timestamp,page_count,toner_black" {
+		t.Fatalf("selected columns = %v, %v", cols, err)
+	}
+	if _, err := metricsExportColumns("timestamp,serial_number", snapshots); err == nil {
+		t.Fatal("expected unknown column to be rejected")
+	}
+}
+
+func TestWriteMetricsCSV(t *testing.T) {
+	t.Parallel()
+	ts := time.Date(2026, 3, 1, 8, 30, 0, 0, time.UTC)
+	first := sparkSnap(ts, 1000, map[string]interface{}{"black": 42.5})
+	first.Tier = "daily"
+	second := sparkSnap(ts.Add(time.Hour), 1010, map[string]interface{}{"black": -3.0})
+	second.Tier = "raw"
+
+	var buf bytes.Buffer
+	if err := writeMetricsCSV(&buf, []string{"timestamp", "tier", "page_count", "toner_black"}, []*storage.MetricsSnapshot{first, second}); err != nil {
+		t.Fatalf("writeMetricsCSV: %v", err)
+	}
+	want := "timestamp,tier,page_count,toner_black\n" +
+		"2026-03-01T08:30:00Z,daily,1000,42.5\n" +
+		"2026-03-01T09:30:00Z,raw,1010,\n"
+	if buf.String() != want {
+		t.Fatalf("csv =\n%s\nwant\n%s", buf.String(), want)
+	}
+}
+
+func TestWriteMetricsParquet(t *testing.T) {
+	t.Parallel()
+	snap := sparkSnap(time.Now(), 5, map[string]interface{}{"black": 10.0})
+	var buf bytes.Buffer
+	if err := writeMetricsParquet(&buf, []string{"timestamp", "tier", "page_count", "toner_black"}, []*storage.MetricsSnapshot{snap}); err != nil {
+		t.Fatalf("writeMetricsParquet: %v", err)
+	}
+	data := buf.Bytes()
+	if !bytes.HasPrefix(data, []byte("PAR1")) || !bytes.HasSuffix(data, []byte("PAR1")) {
+		t.Fatal("expected a Parquet file")
+	}
+	if !bytes.Contains(data, []byte("toner_black")) {
+		t.Fatal("expected schema to name the toner column")
+	}
+}
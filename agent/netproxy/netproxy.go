@@ -0,0 +1,394 @@
+// Package netproxy selects the HTTP proxy used for the agent's outbound
+// traffic to the PrintMaster server: uploads, the WebSocket channel, server
+// login, update downloads and server probes. LAN destinations and hosts on the
+// no-proxy list always go direct, so device traffic never leaves the site.
+package netproxy
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	pacRefreshInterval = time.Hour
+	pacRetryInterval   = 5 * time.Minute
+	pacFetchTimeout    = 10 * time.Second
+	pacMaxSize         = 1 << 20
+)
+
+// Config holds the outbound proxy settings.
+type Config struct {
+	// URL is the proxy used when no PAC file is configured or the PAC file
+	// cannot be evaluated, e.g. http://proxy.corp:3128
+	URL      string
+	Username string
+	Password string
+	// NoProxy lists destinations that bypass the proxy: host names, domain
+	// suffixes (".corp.local" or "corp.local"), IP addresses, CIDR ranges or
+	// "*" for everything. Entries may include a port (host:8443).
+	NoProxy []string
+	// PACURL points at a proxy auto-config file (http(s):// URL or a local
+	// path). Only a subset of PAC JavaScript is supported, see ParsePAC.
+	PACURL string
+	// BypassLAN sends loopback, private and link-local destinations, plain
+	// host names and .local names direct.
+	BypassLAN bool
+}
+
+// Enabled reports whether a proxy or PAC file is configured.
+func (c Config) Enabled() bool {
+	return strings.TrimSpace(c.URL) != "" || strings.TrimSpace(c.PACURL) != ""
+}
+
+// Resolver picks the proxy for each outbound request. A nil *Resolver always
+// connects direct.
+type Resolver struct {
+	cfg     Config
+	static  *url.URL
+	noProxy []noProxyEntry
+
+	// fetchPAC loads the PAC script; replaced in tests
+	fetchPAC func(ctx context.Context, location string) (string, error)
+
+	mu           sync.Mutex
+	pac          *PAC
+	pacLoadedAt  time.Time
+	pacAttemptAt time.Time
+	pacErr       error
+}
+
+// New validates cfg and returns its Resolver, or nil when no proxy is
+// configured.
+func New(cfg Config) (*Resolver, error) {
+	if !cfg.Enabled() {
+		return nil, nil
+	}
+	r := &Resolver{cfg: cfg, fetchPAC: fetchPAC}
+	if raw := strings.TrimSpace(cfg.URL); raw != "" {
+		u, err := parseProxyURL(raw)
+		if err != nil {
+			return nil, err
+		}
+		r.static = r.withCredentials(u)
+	}
+	for _, entry := range cfg.NoProxy {
+		if e, ok := parseNoProxyEntry(entry); ok {
+			r.noProxy = append(r.noProxy, e)
+		}
+	}
+	return r, nil
+}
+
+// Func adapts the resolver for http.Transport.Proxy.
+func (r *Resolver) Func() func(*http.Request) (*url.URL, error) {
+	return func(req *http.Request) (*url.URL, error) {
+		return r.ProxyFor(req.Context(), req.URL)
+	}
+}
+
+// ProxyFor returns the proxy to use for target, or nil to connect direct.
+func (r *Resolver) ProxyFor(ctx context.Context, target *url.URL) (*url.URL, error) {
+	if r == nil || target == nil {
+		return nil, nil
+	}
+	host := target.Hostname()
+	port := target.Port()
+	if port == "" {
+		port = defaultPort(target.Scheme)
+	}
+	if r.bypass(host, port) {
+		return nil, nil
+	}
+	if strings.TrimSpace(r.cfg.PACURL) != "" {
+		if pac, err := r.loadPAC(ctx); err == nil {
+			if proxy, err := pac.Eval(pacURL(target), host); err == nil {
+				return r.withCredentials(proxy), nil
+			}
+		}
+	}
+	return r.static, nil
+}
+
+// Bypass reports whether requests to host:port go direct because of the
+// no-proxy list or LAN bypass.
+func (r *Resolver) Bypass(host, port string) bool {
+	return r == nil || r.bypass(host, port)
+}
+
+// PACError returns the last PAC load error, if any.
+func (r *Resolver) PACError() error {
+	if r == nil {
+		return nil
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.pacErr
+}
+
+func (r *Resolver) bypass(host, port string) bool {
+	host = strings.ToLower(strings.TrimSuffix(host, "."))
+	if host == "" {
+		return true
+	}
+	if r.cfg.BypassLAN && isLANHost(host) {
+		return true
+	}
+	ip := net.ParseIP(host)
+	for _, e := range r.noProxy {
+		if e.matches(host, ip, port) {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *Resolver) withCredentials(u *url.URL) *url.URL {
+	if u == nil || u.User != nil || r.cfg.Username == "" {
+		return u
+	}
+	out := *u
+	out.User = url.UserPassword(r.cfg.Username, r.cfg.Password)
+	return &out
+}
+
+func (r *Resolver) loadPAC(ctx context.Context) (*PAC, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	now := time.Now()
+	if r.pac != nil && now.Sub(r.pacLoadedAt) < pacRefreshInterval {
+		return r.pac, nil
+	}
+	if r.pac == nil && !r.pacAttemptAt.IsZero() && now.Sub(r.pacAttemptAt) < pacRetryInterval {
+		return nil, r.pacErr
+	}
+	r.pacAttemptAt = now
+
+	fetchCtx, cancel := context.WithTimeout(ctx, pacFetchTimeout)
+	defer cancel()
+	script, err := r.fetchPAC(fetchCtx, strings.TrimSpace(r.cfg.PACURL))
+	if err == nil {
+		var pac *PAC
+		if pac, err = ParsePAC(script); err == nil {
+			r.pac, r.pacLoadedAt, r.pacErr = pac, now, nil
+			return pac, nil
+		}
+	}
+	r.pacErr = err
+	// Keep using the last good script until a refresh succeeds
+	if r.pac != nil {
+		return r.pac, nil
+	}
+	return nil, err
+}
+
+// fetchPAC reads a PAC file from a URL or local path. The PAC server itself
+// is always contacted direct.
+func fetchPAC(ctx context.Context, location string) (string, error) {
+	if !strings.HasPrefix(location, "http://") && !strings.HasPrefix(location, "https://") {
+		data, err := os.ReadFile(strings.TrimPrefix(location, "file://"))
+		if err != nil {
+			return "", fmt.Errorf("read PAC file: %w", err)
+		}
+		return string(data), nil
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, location, nil)
+	if err != nil {
+		return "", err
+	}
+	client := &http.Client{Transport: &http.Transport{Proxy: nil}}
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("fetch PAC file: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("fetch PAC file: status %d", resp.StatusCode)
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, pacMaxSize))
+	if err != nil {
+		return "", fmt.Errorf("fetch PAC file: %w", err)
+	}
+	return string(data), nil
+}
+
+// DialContext connects to addr (host:port) through proxy using an HTTP
+// CONNECT tunnel. A nil proxy dials addr directly.
+func DialContext(ctx context.Context, dialer *net.Dialer, proxy *url.URL, addr string) (net.Conn, error) {
+	if proxy == nil {
+		return dialer.DialContext(ctx, "tcp", addr)
+	}
+	proxyAddr := proxy.Host
+	if proxy.Port() == "" {
+		proxyAddr = net.JoinHostPort(proxy.Hostname(), defaultPort(proxy.Scheme))
+	}
+	conn, err := dialer.DialContext(ctx, "tcp", proxyAddr)
+	if err != nil {
+		return nil, fmt.Errorf("connect to proxy %s: %w", proxyAddr, err)
+	}
+	if proxy.Scheme == "https" {
+		tlsConn := tls.Client(conn, &tls.Config{ServerName: proxy.Hostname(), MinVersion: tls.VersionTLS12})
+		if err := tlsConn.HandshakeContext(ctx); err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("proxy TLS handshake: %w", err)
+		}
+		conn = tlsConn
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+		defer conn.SetDeadline(time.Time{})
+	}
+
+	req := &http.Request{
+		Method: http.MethodConnect,
+		URL:    &url.URL{Opaque: addr},
+		Host:   addr,
+		Header: make(http.Header),
+	}
+	if proxy.User != nil {
+		password, _ := proxy.User.Password()
+		creds := base64.StdEncoding.EncodeToString([]byte(proxy.User.Username() + ":" + password))
+		req.Header.Set("Proxy-Authorization", "Basic "+creds)
+	}
+	if err := req.Write(conn); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("proxy CONNECT: %w", err)
+	}
+	br := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(br, req)
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("proxy CONNECT: %w", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		conn.Close()
+		return nil, fmt.Errorf("proxy CONNECT to %s: %s", addr, resp.Status)
+	}
+	if br.Buffered() > 0 {
+		return &bufferedConn{Conn: conn, r: br}, nil
+	}
+	return conn, nil
+}
+
+// bufferedConn hands out bytes read past the CONNECT response first.
+type bufferedConn struct {
+	net.Conn
+	r *bufio.Reader
+}
+
+func (c *bufferedConn) Read(p []byte) (int, error) {
+	return c.r.Read(p)
+}
+
+func parseProxyURL(raw string) (*url.URL, error) {
+	if !strings.Contains(raw, "://") {
+		raw = "http://" + raw
+	}
+	u, err := url.Parse(raw)
+	if err != nil {
+		return nil, fmt.Errorf("invalid proxy URL: %w", err)
+	}
+	switch u.Scheme {
+	case "http", "https":
+	default:
+		return nil, fmt.Errorf("unsupported proxy scheme %q (use http or https)", u.Scheme)
+	}
+	if u.Hostname() == "" {
+		return nil, fmt.Errorf("proxy URL missing host")
+	}
+	return u, nil
+}
+
+func defaultPort(scheme string) string {
+	switch strings.ToLower(scheme) {
+	case "http", "ws":
+		return "80"
+	default:
+		return "443"
+	}
+}
+
+// pacURL strips credentials and, for https, the path and query from the URL
+// handed to the PAC script, as browsers do.
+func pacURL(u *url.URL) string {
+	out := *u
+	out.User = nil
+	switch out.Scheme {
+	case "ws":
+		out.Scheme = "http"
+	case "wss":
+		out.Scheme = "https"
+	}
+	if out.Scheme == "https" {
+		out.Path, out.RawPath, out.RawQuery, out.Fragment = "/", "", "", ""
+	}
+	return out.String()
+}
+
+func isLANHost(host string) bool {
+	if host == "localhost" || strings.HasSuffix(host, ".local") || strings.HasSuffix(host, ".localhost") {
+		return true
+	}
+	if ip := net.ParseIP(host); ip != nil {
+		return ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast()
+	}
+	return !strings.Contains(host, ".")
+}
+
+type noProxyEntry struct {
+	all  bool
+	host string // host name, also matching its sub-domains
+	ip   net.IP
+	cidr *net.IPNet
+	port string
+}
+
+func parseNoProxyEntry(raw string) (noProxyEntry, bool) {
+	raw = strings.ToLower(strings.TrimSpace(raw))
+	if raw == "" {
+		return noProxyEntry{}, false
+	}
+	if raw == "*" {
+		return noProxyEntry{all: true}, true
+	}
+	if _, cidr, err := net.ParseCIDR(raw); err == nil {
+		return noProxyEntry{cidr: cidr}, true
+	}
+	var e noProxyEntry
+	if h, p, err := net.SplitHostPort(raw); err == nil {
+		raw, e.port = h, p
+	}
+	if ip := net.ParseIP(strings.Trim(raw, "[]")); ip != nil {
+		e.ip = ip
+		return e, true
+	}
+	e.host = strings.TrimPrefix(strings.TrimPrefix(raw, "*"), ".")
+	return e, e.host != ""
+}
+
+func (e noProxyEntry) matches(host string, ip net.IP, port string) bool {
+	switch {
+	case e.all:
+		return true
+	case e.port != "" && e.port != port:
+		return false
+	case e.cidr != nil:
+		return ip != nil && e.cidr.Contains(ip)
+	case e.ip != nil:
+		return ip != nil && e.ip.Equal(ip)
+	default:
+		// "corp.local" and ".corp.local" both match sub-domains, like curl
+		return host == e.host || strings.HasSuffix(host, "."+e.host)
+	}
+}
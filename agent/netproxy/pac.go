@@ -0,0 +1,608 @@
+package netproxy
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// PAC is a parsed proxy auto-config script.
+//
+// Only the subset of PAC JavaScript found in typical corporate PAC files is
+// supported: a FindProxyForURL(url, host) function made of if/else and return
+// statements, string comparisons with == and !=, the operators !, && and ||,
+// and the helpers isPlainHostName, dnsDomainIs, localHostOrDomainIs,
+// shExpMatch, isInNet, isResolvable and dnsResolve. Scripts using anything
+// else are rejected by ParsePAC and the static proxy URL is used instead.
+type PAC struct {
+	body []pacStmt
+
+	// lookupIP resolves host names for isInNet and friends; replaced in tests
+	lookupIP func(host string) ([]net.IP, error)
+}
+
+// ParsePAC parses script.
+func ParsePAC(script string) (*PAC, error) {
+	toks, err := pacTokenize(script)
+	if err != nil {
+		return nil, err
+	}
+	p := &pacParser{toks: toks}
+	body, err := p.parseFunction()
+	if err != nil {
+		return nil, fmt.Errorf("PAC: %w", err)
+	}
+	return &PAC{body: body, lookupIP: net.LookupIP}, nil
+}
+
+// Eval runs FindProxyForURL and returns the first usable proxy it names, or
+// nil for DIRECT.
+func (p *PAC) Eval(rawURL, host string) (*url.URL, error) {
+	env := &pacEnv{pac: p, vars: map[string]string{"url": rawURL, "host": strings.ToLower(host)}}
+	result, ok, err := env.run(p.body)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, fmt.Errorf("PAC: FindProxyForURL returned nothing")
+	}
+	return parsePACResult(result)
+}
+
+// parsePACResult picks the first PROXY/HTTP/HTTPS or DIRECT entry from a
+// result such as "PROXY a:3128; PROXY b:3128; DIRECT". SOCKS entries are
+// skipped.
+func parsePACResult(result string) (*url.URL, error) {
+	for _, entry := range strings.Split(result, ";") {
+		fields := strings.Fields(entry)
+		if len(fields) == 0 {
+			continue
+		}
+		kind := strings.ToUpper(fields[0])
+		if kind == "DIRECT" {
+			return nil, nil
+		}
+		if len(fields) < 2 {
+			continue
+		}
+		switch kind {
+		case "PROXY", "HTTP":
+			return parseProxyURL("http://" + fields[1])
+		case "HTTPS":
+			return parseProxyURL("https://" + fields[1])
+		}
+	}
+	return nil, fmt.Errorf("PAC: no usable proxy in %q", result)
+}
+
+// Tokenizer
+
+type pacToken struct {
+	kind string // "ident", "string", "number" or the punctuation itself
+	text string
+}
+
+func pacTokenize(src string) ([]pacToken, error) {
+	var toks []pacToken
+	for i := 0; i < len(src); {
+		c := src[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\r' || c == '\n':
+			i++
+		case strings.HasPrefix(src[i:], "//"):
+			for i < len(src) && src[i] != '\n' {
+				i++
+			}
+		case strings.HasPrefix(src[i:], "/*"):
+			end := strings.Index(src[i+2:], "*/")
+			if end < 0 {
+				return nil, fmt.Errorf("PAC: unterminated comment")
+			}
+			i += end + 4
+		case c == '"' || c == '\'':
+			j := i + 1
+			var sb strings.Builder
+			for ; j < len(src) && src[j] != c; j++ {
+				if src[j] == '\\' && j+1 < len(src) {
+					j++
+				}
+				sb.WriteByte(src[j])
+			}
+			if j >= len(src) {
+				return nil, fmt.Errorf("PAC: unterminated string")
+			}
+			toks = append(toks, pacToken{kind: "string", text: sb.String()})
+			i = j + 1
+		case isIdentByte(c):
+			j := i
+			for j < len(src) && (isIdentByte(src[j]) || (src[j] >= '0' && src[j] <= '9')) {
+				j++
+			}
+			toks = append(toks, pacToken{kind: "ident", text: src[i:j]})
+			i = j
+		case c >= '0' && c <= '9':
+			j := i
+			for j < len(src) && src[j] >= '0' && src[j] <= '9' {
+				j++
+			}
+			toks = append(toks, pacToken{kind: "number", text: src[i:j]})
+			i = j
+		default:
+			matched := false
+			for _, op := range []string{"===", "!==", "==", "!=", "&&", "||", "(", ")", "{", "}", ";", ",", "!"} {
+				if strings.HasPrefix(src[i:], op) {
+					kind := op
+					switch op {
+					case "===":
+						kind = "=="
+					case "!==":
+						kind = "!="
+					}
+					toks = append(toks, pacToken{kind: kind, text: op})
+					i += len(op)
+					matched = true
+					break
+				}
+			}
+			if !matched {
+				return nil, fmt.Errorf("PAC: unsupported character %q", c)
+			}
+		}
+	}
+	return toks, nil
+}
+
+func isIdentByte(c byte) bool {
+	return c == '_' || c == '$' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+// Parser
+
+type pacStmt interface{}
+
+type pacIf struct {
+	cond pacExpr
+	then []pacStmt
+	els  []pacStmt
+}
+
+type pacReturn struct {
+	value pacExpr
+}
+
+type pacExpr interface{}
+
+type pacString string
+
+type pacVar string
+
+type pacNot struct{ x pacExpr }
+
+type pacBinary struct {
+	op   string
+	l, r pacExpr
+}
+
+type pacCall struct {
+	name string
+	args []pacExpr
+}
+
+var pacBuiltins = map[string]int{
+	"isPlainHostName":     1,
+	"dnsDomainIs":         2,
+	"localHostOrDomainIs": 2,
+	"shExpMatch":          2,
+	"isInNet":             3,
+	"isResolvable":        1,
+	"dnsResolve":          1,
+}
+
+type pacParser struct {
+	toks []pacToken
+	pos  int
+}
+
+func (p *pacParser) peek() pacToken {
+	if p.pos < len(p.toks) {
+		return p.toks[p.pos]
+	}
+	return pacToken{kind: "eof"}
+}
+
+func (p *pacParser) next() pacToken {
+	t := p.peek()
+	p.pos++
+	return t
+}
+
+func (p *pacParser) expect(kind string) (pacToken, error) {
+	t := p.next()
+	if t.kind != kind {
+		return t, fmt.Errorf("expected %q, got %q", kind, t.text)
+	}
+	return t, nil
+}
+
+func (p *pacParser) parseFunction() ([]pacStmt, error) {
+	if t := p.next(); t.kind != "ident" || t.text != "function" {
+		return nil, fmt.Errorf("expected function FindProxyForURL")
+	}
+	if t := p.next(); t.text != "FindProxyForURL" {
+		return nil, fmt.Errorf("expected FindProxyForURL, got %q", t.text)
+	}
+	if _, err := p.expect("("); err != nil {
+		return nil, err
+	}
+	for _, name := range []string{"url", "host"} {
+		t, err := p.expect("ident")
+		if err != nil {
+			return nil, err
+		}
+		if t.text != name {
+			return nil, fmt.Errorf("FindProxyForURL parameters must be (url, host)")
+		}
+		if name == "url" {
+			if _, err := p.expect(","); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if _, err := p.expect(")"); err != nil {
+		return nil, err
+	}
+	body, err := p.parseBlock()
+	if err != nil {
+		return nil, err
+	}
+	if t := p.peek(); t.kind != "eof" {
+		return nil, fmt.Errorf("unexpected %q after FindProxyForURL", t.text)
+	}
+	return body, nil
+}
+
+func (p *pacParser) parseBlock() ([]pacStmt, error) {
+	if _, err := p.expect("{"); err != nil {
+		return nil, err
+	}
+	var stmts []pacStmt
+	for p.peek().kind != "}" {
+		if p.peek().kind == "eof" {
+			return nil, fmt.Errorf("unexpected end of script")
+		}
+		s, err := p.parseStmt()
+		if err != nil {
+			return nil, err
+		}
+		if s != nil {
+			stmts = append(stmts, s)
+		}
+	}
+	p.next()
+	return stmts, nil
+}
+
+// parseBody parses the statement or block following if/else.
+func (p *pacParser) parseBody() ([]pacStmt, error) {
+	if p.peek().kind == "{" {
+		return p.parseBlock()
+	}
+	s, err := p.parseStmt()
+	if err != nil {
+		return nil, err
+	}
+	return []pacStmt{s}, nil
+}
+
+func (p *pacParser) parseStmt() (pacStmt, error) {
+	t := p.peek()
+	switch {
+	case t.kind == ";":
+		p.next()
+		return nil, nil
+	case t.kind == "ident" && t.text == "if":
+		p.next()
+		if _, err := p.expect("("); err != nil {
+			return nil, err
+		}
+		cond, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if _, err := p.expect(")"); err != nil {
+			return nil, err
+		}
+		stmt := &pacIf{cond: cond}
+		if stmt.then, err = p.parseBody(); err != nil {
+			return nil, err
+		}
+		if n := p.peek(); n.kind == "ident" && n.text == "else" {
+			p.next()
+			if stmt.els, err = p.parseBody(); err != nil {
+				return nil, err
+			}
+		}
+		return stmt, nil
+	case t.kind == "ident" && t.text == "return":
+		p.next()
+		value, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if p.peek().kind == ";" {
+			p.next()
+		}
+		return &pacReturn{value: value}, nil
+	}
+	return nil, fmt.Errorf("unsupported statement starting with %q", t.text)
+}
+
+func (p *pacParser) parseOr() (pacExpr, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek().kind == "||" {
+		p.next()
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = &pacBinary{op: "||", l: left, r: right}
+	}
+	return left, nil
+}
+
+func (p *pacParser) parseAnd() (pacExpr, error) {
+	left, err := p.parseCompare()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek().kind == "&&" {
+		p.next()
+		right, err := p.parseCompare()
+		if err != nil {
+			return nil, err
+		}
+		left = &pacBinary{op: "&&", l: left, r: right}
+	}
+	return left, nil
+}
+
+func (p *pacParser) parseCompare() (pacExpr, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	if k := p.peek().kind; k == "==" || k == "!=" {
+		p.next()
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return &pacBinary{op: k, l: left, r: right}, nil
+	}
+	return left, nil
+}
+
+func (p *pacParser) parseUnary() (pacExpr, error) {
+	t := p.next()
+	switch t.kind {
+	case "!":
+		x, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return &pacNot{x: x}, nil
+	case "(":
+		x, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if _, err := p.expect(")"); err != nil {
+			return nil, err
+		}
+		return x, nil
+	case "string":
+		return pacString(t.text), nil
+	case "ident":
+		if t.text == "url" || t.text == "host" {
+			return pacVar(t.text), nil
+		}
+		arity, ok := pacBuiltins[t.text]
+		if !ok {
+			return nil, fmt.Errorf("unsupported identifier %q", t.text)
+		}
+		if _, err := p.expect("("); err != nil {
+			return nil, err
+		}
+		call := &pacCall{name: t.text}
+		for p.peek().kind != ")" {
+			if len(call.args) > 0 {
+				if _, err := p.expect(","); err != nil {
+					return nil, err
+				}
+			}
+			arg, err := p.parseOr()
+			if err != nil {
+				return nil, err
+			}
+			call.args = append(call.args, arg)
+		}
+		p.next()
+		if len(call.args) != arity {
+			return nil, fmt.Errorf("%s expects %d arguments", t.text, arity)
+		}
+		return call, nil
+	}
+	return nil, fmt.Errorf("unexpected %q", t.text)
+}
+
+// Evaluator
+
+type pacEnv struct {
+	pac  *PAC
+	vars map[string]string
+}
+
+// run executes statements and returns the value of the first return reached.
+func (e *pacEnv) run(stmts []pacStmt) (string, bool, error) {
+	for _, s := range stmts {
+		switch s := s.(type) {
+		case *pacReturn:
+			v, err := e.eval(s.value)
+			if err != nil {
+				return "", false, err
+			}
+			str, ok := v.(string)
+			if !ok {
+				return "", false, fmt.Errorf("PAC: FindProxyForURL must return a string")
+			}
+			return str, true, nil
+		case *pacIf:
+			cond, err := e.evalBool(s.cond)
+			if err != nil {
+				return "", false, err
+			}
+			branch := s.els
+			if cond {
+				branch = s.then
+			}
+			if result, ok, err := e.run(branch); err != nil || ok {
+				return result, ok, err
+			}
+		}
+	}
+	return "", false, nil
+}
+
+func (e *pacEnv) evalBool(x pacExpr) (bool, error) {
+	v, err := e.eval(x)
+	if err != nil {
+		return false, err
+	}
+	switch v := v.(type) {
+	case bool:
+		return v, nil
+	case string:
+		return v != "", nil
+	}
+	return false, nil
+}
+
+func (e *pacEnv) evalString(x pacExpr) (string, error) {
+	v, err := e.eval(x)
+	if err != nil {
+		return "", err
+	}
+	s, ok := v.(string)
+	if !ok {
+		return "", fmt.Errorf("PAC: expected a string argument")
+	}
+	return s, nil
+}
+
+func (e *pacEnv) eval(x pacExpr) (interface{}, error) {
+	switch x := x.(type) {
+	case pacString:
+		return string(x), nil
+	case pacVar:
+		return e.vars[string(x)], nil
+	case *pacNot:
+		b, err := e.evalBool(x.x)
+		return !b, err
+	case *pacBinary:
+		switch x.op {
+		case "||", "&&":
+			l, err := e.evalBool(x.l)
+			if err != nil {
+				return nil, err
+			}
+			if (x.op == "||") == l {
+				return l, nil
+			}
+			return e.evalBool(x.r)
+		default:
+			l, err := e.eval(x.l)
+			if err != nil {
+				return nil, err
+			}
+			r, err := e.eval(x.r)
+			if err != nil {
+				return nil, err
+			}
+			return (l == r) == (x.op == "=="), nil
+		}
+	case *pacCall:
+		args := make([]string, len(x.args))
+		for i, a := range x.args {
+			s, err := e.evalString(a)
+			if err != nil {
+				return nil, err
+			}
+			args[i] = s
+		}
+		return e.call(x.name, args)
+	}
+	return nil, fmt.Errorf("PAC: unsupported expression")
+}
+
+func (e *pacEnv) call(name string, args []string) (interface{}, error) {
+	switch name {
+	case "isPlainHostName":
+		return !strings.Contains(args[0], "."), nil
+	case "dnsDomainIs":
+		return strings.HasSuffix(strings.ToLower(args[0]), strings.ToLower(args[1])), nil
+	case "localHostOrDomainIs":
+		h, hd := strings.ToLower(args[0]), strings.ToLower(args[1])
+		return h == hd || (!strings.Contains(h, ".") && strings.HasPrefix(hd, h+".")), nil
+	case "shExpMatch":
+		return shExpMatch(args[0], args[1]), nil
+	case "isInNet":
+		ip := e.resolve(args[0])
+		pattern, mask := net.ParseIP(args[1]), net.ParseIP(args[2])
+		if ip == nil || pattern == nil || mask == nil || ip.To4() == nil || pattern.To4() == nil || mask.To4() == nil {
+			return false, nil
+		}
+		m := net.IPMask(mask.To4())
+		return ip.To4().Mask(m).Equal(pattern.To4().Mask(m)), nil
+	case "isResolvable":
+		return e.resolve(args[0]) != nil, nil
+	case "dnsResolve":
+		if ip := e.resolve(args[0]); ip != nil {
+			return ip.String(), nil
+		}
+		return "", nil
+	}
+	return nil, fmt.Errorf("PAC: unsupported function %s", name)
+}
+
+func (e *pacEnv) resolve(host string) net.IP {
+	if ip := net.ParseIP(host); ip != nil {
+		return ip
+	}
+	ips, err := e.pac.lookupIP(host)
+	if err != nil {
+		return nil
+	}
+	for _, ip := range ips {
+		if ip.To4() != nil {
+			return ip
+		}
+	}
+	return nil
+}
+
+// shExpMatch matches a shell glob where * and ? also match '/'.
+func shExpMatch(s, pattern string) bool {
+	expr := regexp.QuoteMeta(pattern)
+	expr = strings.ReplaceAll(expr, `\*`, ".*")
+	expr = strings.ReplaceAll(expr, `\?`, ".")
+	re, err := regexp.Compile("^(?s:" + expr + ")$")
+	if err != nil {
+		return false
+	}
+	return re.MatchString(s)
+}
@@ -0,0 +1,85 @@
+package netproxy
+
+import (
+	"net"
+	"testing"
+)
+
+const testPAC = `
+// Corporate PAC file
+function FindProxyForURL(url, host) {
+	/* Intranet goes direct */
+	if (isPlainHostName(host) || dnsDomainIs(host, ".corp.example") ||
+	    localHostOrDomainIs(host, "intranet.example.com"))
+		return "DIRECT";
+
+	if (isInNet(dnsResolve(host), "10.0.0.0", "255.0.0.0")) {
+		return 'DIRECT';
+	} else if (shExpMatch(url, "https://*.eu.example.com/*") && host != "skip.eu.example.com") {
+		return "SOCKS eu-socks:1080; HTTPS eu-proxy:443";
+	}
+	return "PROXY proxy1:3128; PROXY proxy2:3128; DIRECT";
+}
+`
+
+func TestPACEval(t *testing.T) {
+	t.Parallel()
+	pac, err := ParsePAC(testPAC)
+	if err != nil {
+		t.Fatalf("ParsePAC: %v", err)
+	}
+	pac.lookupIP = func(host string) ([]net.IP, error) {
+		if host == "build.internal.example" {
+			return []net.IP{net.ParseIP("10.4.0.2")}, nil
+		}
+		return []net.IP{net.ParseIP("198.51.100.10")}, nil
+	}
+	cases := []struct {
+		url, host, want string
+	}{
+		{"https://printmaster/", "printmaster", ""},
+		{"https://pm.corp.example/", "pm.corp.example", ""},
+		{"https://intranet/", "intranet", ""},
+		{"https://build.internal.example/", "build.internal.example", ""},
+		{"https://pm.eu.example.com/", "pm.eu.example.com", "https://eu-proxy:443"},
+		{"https://skip.eu.example.com/", "skip.eu.example.com", "http://proxy1:3128"},
+		{"https://pm.example.com/", "pm.example.com", "http://proxy1:3128"},
+	}
+	for _, tc := range cases {
+		got, err := pac.Eval(tc.url, tc.host)
+		if err != nil {
+			t.Fatalf("Eval(%s): %v", tc.url, err)
+		}
+		gotStr := ""
+		if got != nil {
+			gotStr = got.String()
+		}
+		if gotStr != tc.want {
+			t.Errorf("Eval(%s) = %q, want %q", tc.url, gotStr, tc.want)
+		}
+	}
+}
+
+func TestParsePACRejectsUnsupported(t *testing.T) {
+	t.Parallel()
+	for _, script := range []string{
+		`function FindProxyForURL(url, host) { var p = "PROXY a:1"; return p; }`,
+		`function FindProxyForURL(url, host) { return myIpAddress(); }`,
+		`function FindProxyForURL(url, host) { return "DIRECT";`,
+		`function Other(url, host) { return "DIRECT"; }`,
+	} {
+		if _, err := ParsePAC(script); err == nil {
+			t.Errorf("expected ParsePAC to reject %q", script)
+		}
+	}
+}
+
+func TestShExpMatch(t *testing.T) {
+	t.Parallel()
+	if !shExpMatch("http://a.example.com/x/y", "http://*.example.com/*") {
+		t.Error("expected * to match across path separators")
+	}
+	if shExpMatch("a.example.org", "*.example.com") || !shExpMatch("ab", "a?") {
+		t.Error("unexpected shExpMatch result")
+	}
+}
@@ -0,0 +1,341 @@
+package main
+
+import (
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"printmaster/agent/agent"
+	"printmaster/agent/storage"
+
+	"github.com/gosnmp/gosnmp"
+)
+
+const (
+	oidBrowserHistorySize   = 50
+	oidBrowserDefaultMax    = 500
+	oidBrowserMaxEntries    = 5000
+	oidBrowserMaxGetOIDs    = 60
+	oidBrowserHistoryValues = 20 // results kept per history entry
+)
+
+var errOIDWalkLimit = errors.New("walk limit reached")
+
+// oidResult is one varbind returned by the OID browser. Hex is set for octet
+// strings that aren't printable text (MAC addresses, bitmaps).
+type oidResult struct {
+	OID   string `json:"oid"`
+	Type  string `json:"type"`
+	Value string `json:"value"`
+	Hex   string `json:"hex,omitempty"`
+}
+
+// oidQuery is an OID browser request and, in history, its outcome.
+type oidQuery struct {
+	Serial     string      `json:"serial,omitempty"`
+	IP         string      `json:"ip"`
+	Mode       string      `json:"mode"` // get or walk
+	OIDs       []string    `json:"oids"`
+	MaxEntries int         `json:"max_entries,omitempty"`
+	At         time.Time   `json:"at"`
+	DurationMS int64       `json:"duration_ms"`
+	Count      int         `json:"count"`
+	Truncated  bool        `json:"truncated,omitempty"`
+	Error      string      `json:"error,omitempty"`
+	Results    []oidResult `json:"results,omitempty"`
+}
+
+// oidBrowser runs ad-hoc SNMP GETs and walks for troubleshooting and keeps
+// a short in-memory history of recent queries.
+type oidBrowser struct {
+	newClient func(ip string) (agent.SNMPClient, error)
+
+	mu      sync.Mutex
+	history []oidQuery
+}
+
+var snmpBrowser = newOIDBrowser()
+
+func newOIDBrowser() *oidBrowser {
+	return &oidBrowser{
+		newClient: func(ip string) (agent.SNMPClient, error) {
+			cfg, err := agent.GetSNMPConfig()
+			if err != nil {
+				return nil, err
+			}
+			return agent.NewSNMPClient(cfg, ip, 5)
+		},
+	}
+}
+
+// normalizeOID trims whitespace and the leading dot and checks the OID is
+// numeric.
+func normalizeOID(oid string) (string, error) {
+	oid = strings.TrimPrefix(strings.TrimSpace(oid), ".")
+	if oid == "" {
+		return "", fmt.Errorf("empty OID")
+	}
+	for _, part := range strings.Split(oid, ".") {
+		if part == "" || strings.Trim(part, "0123456789") != "" {
+			return "", fmt.Errorf("invalid OID %q", oid)
+		}
+	}
+	return oid, nil
+}
+
+// Run executes q against q.IP and records it in the history.
+func (b *oidBrowser) Run(q oidQuery) oidQuery {
+	start := time.Now()
+	q.At = start.UTC()
+	results, truncated, err := b.query(q)
+	q.DurationMS = time.Since(start).Milliseconds()
+	q.Results = results
+	q.Count = len(results)
+	q.Truncated = truncated
+	if err != nil {
+		q.Error = err.Error()
+	}
+
+	entry := q
+	if len(entry.Results) > oidBrowserHistoryValues {
+		entry.Results = entry.Results[:oidBrowserHistoryValues]
+	}
+	b.mu.Lock()
+	b.history = append([]oidQuery{entry}, b.history...)
+	if len(b.history) > oidBrowserHistorySize {
+		b.history = b.history[:oidBrowserHistorySize]
+	}
+	b.mu.Unlock()
+	return q
+}
+
+func (b *oidBrowser) query(q oidQuery) ([]oidResult, bool, error) {
+	client, err := b.newClient(q.IP)
+	if err != nil {
+		return nil, false, err
+	}
+	defer client.Close()
+
+	var results []oidResult
+	switch q.Mode {
+	case "walk":
+		for _, root := range q.OIDs {
+			err := client.Walk(root, func(pdu gosnmp.SnmpPDU) error {
+				if len(results) >= q.MaxEntries {
+					return errOIDWalkLimit
+				}
+				results = append(results, formatOIDResult(pdu))
+				return nil
+			})
+			if errors.Is(err, errOIDWalkLimit) {
+				return results, true, nil
+			}
+			if err != nil {
+				return results, false, err
+			}
+		}
+	default:
+		packet, err := client.Get(q.OIDs)
+		if err != nil {
+			return nil, false, err
+		}
+		for _, pdu := range packet.Variables {
+			results = append(results, formatOIDResult(pdu))
+		}
+	}
+	return results, false, nil
+}
+
+// History returns recent queries, newest first.
+func (b *oidBrowser) History() []oidQuery {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return append([]oidQuery(nil), b.history...)
+}
+
+func formatOIDResult(pdu gosnmp.SnmpPDU) oidResult {
+	res := oidResult{OID: strings.TrimPrefix(pdu.Name, "."), Type: pdu.Type.String()}
+	switch pdu.Type {
+	case gosnmp.NoSuchObject, gosnmp.NoSuchInstance, gosnmp.EndOfMibView, gosnmp.Null:
+		return res
+	}
+	if raw, ok := pdu.Value.([]byte); ok {
+		text := strings.TrimRight(string(raw), "\x00")
+		if utf8.ValidString(text) && isPrintableText(text) {
+			res.Value = text
+		} else {
+			res.Hex = hex.EncodeToString(raw)
+			res.Value = res.Hex
+		}
+		return res
+	}
+	res.Value = pduString(pdu)
+	return res
+}
+
+func isPrintableText(s string) bool {
+	for _, r := range s {
+		if r < 0x20 && r != '\t' && r != '\n' && r != '\r' {
+			return false
+		}
+	}
+	return true
+}
+
+// setLearnedOID stores oid as the learned mapping for a device field. Fields
+// without a dedicated slot go to VendorSpecificOIDs.
+func setLearnedOID(learned *agent.LearnedOIDMap, field, oid string) {
+	switch strings.ToLower(field) {
+	case "page_count", "total_pages":
+		learned.PageCountOID = oid
+	case "mono_pages", "mono_impressions":
+		learned.MonoPagesOID = oid
+	case "color_pages", "color_impressions":
+		learned.ColorPagesOID = oid
+	case "cyan":
+		learned.CyanOID = oid
+	case "magenta":
+		learned.MagentaOID = oid
+	case "yellow":
+		learned.YellowOID = oid
+	case "serial":
+		learned.SerialOID = oid
+	case "model":
+		learned.ModelOID = oid
+	default:
+		if learned.VendorSpecificOIDs == nil {
+			learned.VendorSpecificOIDs = make(map[string]string)
+		}
+		learned.VendorSpecificOIDs[field] = oid
+	}
+}
+
+// registerOIDBrowserHandlers exposes the admin-only SNMP OID browser.
+func registerOIDBrowserHandlers() {
+	// POST /api/snmp/query - GET or walk arbitrary OIDs against a device
+	http.HandleFunc("/api/snmp/query", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "POST only", http.StatusMethodNotAllowed)
+			return
+		}
+		var req oidQuery
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "bad json", http.StatusBadRequest)
+			return
+		}
+		req.Serial = strings.TrimSpace(req.Serial)
+		req.IP = strings.TrimSpace(req.IP)
+		if req.IP == "" && req.Serial != "" {
+			if dev, err := deviceStore.Get(r.Context(), req.Serial); err == nil {
+				req.IP = dev.IP
+			}
+		}
+		if net.ParseIP(req.IP) == nil {
+			http.Error(w, "valid ip or known serial required", http.StatusBadRequest)
+			return
+		}
+		if req.Mode != "walk" {
+			req.Mode = "get"
+		}
+		if len(req.OIDs) == 0 {
+			http.Error(w, "at least one oid required", http.StatusBadRequest)
+			return
+		}
+		if req.Mode == "get" && len(req.OIDs) > oidBrowserMaxGetOIDs {
+			http.Error(w, fmt.Sprintf("at most %d oids per get", oidBrowserMaxGetOIDs), http.StatusBadRequest)
+			return
+		}
+		for i, oid := range req.OIDs {
+			normalized, err := normalizeOID(oid)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			req.OIDs[i] = normalized
+		}
+		if req.MaxEntries <= 0 {
+			req.MaxEntries = oidBrowserDefaultMax
+		}
+		if req.MaxEntries > oidBrowserMaxEntries {
+			req.MaxEntries = oidBrowserMaxEntries
+		}
+
+		result := snmpBrowser.Run(req)
+		appLogger.Info("OID browser query", "ip", result.IP, "mode", result.Mode, "oids", strings.Join(result.OIDs, ","), "count", result.Count, "error", result.Error)
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(result)
+	})
+
+	// GET /api/snmp/history - Recent OID browser queries, newest first
+	http.HandleFunc("/api/snmp/history", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "GET only", http.StatusMethodNotAllowed)
+			return
+		}
+		history := snmpBrowser.History()
+		if history == nil {
+			history = []oidQuery{}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{"history": history})
+	})
+
+	// POST /api/snmp/learn - Use an OID as the learned mapping for a device field
+	http.HandleFunc("/api/snmp/learn", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "POST only", http.StatusMethodNotAllowed)
+			return
+		}
+		var req struct {
+			Serial string `json:"serial"`
+			Field  string `json:"field"`
+			OID    string `json:"oid"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "bad json", http.StatusBadRequest)
+			return
+		}
+		req.Field = strings.TrimSpace(req.Field)
+		if req.Serial == "" || req.Field == "" {
+			http.Error(w, "serial and field required", http.StatusBadRequest)
+			return
+		}
+		oid, err := normalizeOID(req.OID)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+		defer cancel()
+		device, err := deviceStore.Get(ctx, req.Serial)
+		if err != nil {
+			http.Error(w, "device not found", http.StatusNotFound)
+			return
+		}
+		pi := storage.DeviceToPrinterInfo(device)
+		setLearnedOID(&pi.LearnedOIDs, req.Field, oid)
+		if device.RawData == nil {
+			device.RawData = make(map[string]interface{})
+		}
+		device.RawData["learned_oids"] = pi.LearnedOIDs
+		if err := deviceStore.Update(ctx, device); err != nil {
+			http.Error(w, "failed to save learned oid: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		appLogger.Info("Learned OID set from OID browser", "serial", device.Serial, "field", req.Field, "oid", oid)
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"status":       "ok",
+			"learned_oids": pi.LearnedOIDs,
+		})
+	})
+}
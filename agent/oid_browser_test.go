@@ -0,0 +1,121 @@
+package main
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"printmaster/agent/agent"
+
+	"github.com/gosnmp/gosnmp"
+)
+
+// fakeOIDClient serves a fixed set of varbinds in OID order.
+type fakeOIDClient struct {
+	pdus []gosnmp.SnmpPDU
+}
+
+func (c *fakeOIDClient) Connect() error { return nil }
+func (c *fakeOIDClient) Close() error   { return nil }
+
+func (c *fakeOIDClient) Get(oids []string) (*gosnmp.SnmpPacket, error) {
+	packet := &gosnmp.SnmpPacket{}
+	for _, oid := range oids {
+		found := gosnmp.SnmpPDU{Name: "." + oid, Type: gosnmp.NoSuchObject}
+		for _, p := range c.pdus {
+			if p.Name == "."+oid {
+				found = p
+			}
+		}
+		packet.Variables = append(packet.Variables, found)
+	}
+	return packet, nil
+}
+
+func (c *fakeOIDClient) Walk(root string, walkFn gosnmp.WalkFunc) error {
+	for _, p := range c.pdus {
+		if strings.HasPrefix(p.Name, "."+root+".") {
+			if err := walkFn(p); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func newTestOIDBrowser() *oidBrowser {
+	b := newOIDBrowser()
+	client := &fakeOIDClient{pdus: []gosnmp.SnmpPDU{
+		{Name: ".1.3.6.1.2.1.1.5.0", Type: gosnmp.OctetString, Value: []byte("printer-3f")},
+		{Name: ".1.3.6.1.2.1.2.2.1.6.1", Type: gosnmp.OctetString, Value: []byte{0x00, 0x11, 0x22, 0xaa, 0xbb, 0xcc}},
+		{Name: ".1.3.6.1.2.1.43.10.2.1.4.1.1", Type: gosnmp.Counter32, Value: uint(123456)},
+		{Name: ".1.3.6.1.2.1.43.10.2.1.4.1.2", Type: gosnmp.Counter32, Value: uint(789)},
+	}}
+	b.newClient = func(ip string) (agent.SNMPClient, error) {
+		if ip == "10.0.0.99" {
+			return nil, errors.New("timeout")
+		}
+		return client, nil
+	}
+	return b
+}
+
+func TestOIDBrowserGetAndWalk(t *testing.T) {
+	t.Parallel()
+	b := newTestOIDBrowser()
+
+	got := b.Run(oidQuery{IP: "10.0.0.9", Mode: "get", OIDs: []string{"1.3.6.1.2.1.1.5.0", "1.3.6.1.2.1.2.2.1.6.1", "1.3.6.1.2.1.1.6.0"}})
+	if got.Error != "" || got.Count != 3 {
+		t.Fatalf("unexpected get result: %+v", got)
+	}
+	if got.Results[0].Value != "printer-3f" || got.Results[0].Type != "OctetString" {
+		t.Errorf("unexpected string varbind: %+v", got.Results[0])
+	}
+	if got.Results[1].Hex != "001122aabbcc" {
+		t.Errorf("expected binary octet string as hex, got %+v", got.Results[1])
+	}
+	if got.Results[2].Value != "" || got.Results[2].Type != "NoSuchObject" {
+		t.Errorf("unexpected missing varbind: %+v", got.Results[2])
+	}
+
+	walk := b.Run(oidQuery{IP: "10.0.0.9", Mode: "walk", OIDs: []string{"1.3.6.1.2.1.43.10.2.1.4"}, MaxEntries: 1})
+	if walk.Count != 1 || !walk.Truncated || walk.Results[0].Value != "123456" {
+		t.Fatalf("expected truncated walk with first counter, got %+v", walk)
+	}
+
+	failed := b.Run(oidQuery{IP: "10.0.0.99", Mode: "get", OIDs: []string{"1.3.6.1.2.1.1.5.0"}})
+	if failed.Error == "" {
+		t.Fatal("expected error for unreachable device")
+	}
+
+	history := b.History()
+	if len(history) != 3 || history[0].IP != "10.0.0.99" || history[2].Mode != "get" {
+		t.Fatalf("unexpected history order: %+v", history)
+	}
+}
+
+func TestNormalizeOID(t *testing.T) {
+	t.Parallel()
+	if got, err := normalizeOID(" .1.3.6.1.2.1.1.5.0 "); err != nil || got != "1.3.6.1.2.1.1.5.0" {
+		t.Fatalf("normalizeOID = %q, %v", got, err)
+	}
+	for _, bad := range []string{"", "1.3..6", "sysName.0", "1.3.6.x"} {
+		if _, err := normalizeOID(bad); err == nil {
+			t.Errorf("expected %q to be rejected", bad)
+		}
+	}
+}
+
+func TestSetLearnedOID(t *testing.T) {
+	t.Parallel()
+	var learned agent.LearnedOIDMap
+	setLearnedOID(&learned, "page_count", "1.3.6.1.2.1.43.10.2.1.4.1.1")
+	setLearnedOID(&learned, "Mono_Pages", "1.3.6.1.4.1.1.1")
+	setLearnedOID(&learned, "scan_count", "1.3.6.1.4.1.1.2")
+	if learned.PageCountOID != "1.3.6.1.2.1.43.10.2.1.4.1.1" || learned.MonoPagesOID != "1.3.6.1.4.1.1.1" {
+		t.Fatalf("unexpected learned OIDs: %+v", learned)
+	}
+	if learned.VendorSpecificOIDs["scan_count"] != "1.3.6.1.4.1.1.2" {
+		t.Fatalf("expected vendor-specific mapping, got %+v", learned.VendorSpecificOIDs)
+	}
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"sync/atomic"
+
+	"printmaster/agent/agent"
+	"printmaster/agent/persistfilter"
+)
+
+// persistFilter holds the active discovery persistence filter. A nil value
+// means every discovered device is stored.
+var persistFilter atomic.Pointer[persistfilter.Filter]
+
+// applyPersistFilterSettings rebuilds the persistence filter from a discovery
+// settings map. Missing keys leave the current filter untouched.
+func applyPersistFilterSettings(disc map[string]interface{}) {
+	allowRaw, hasAllow := disc["persist_allow_patterns"]
+	ignoreRaw, hasIgnore := disc["persist_ignore_sys_object_ids"]
+	if !hasAllow && !hasIgnore {
+		return
+	}
+	allow, _ := allowRaw.(string)
+	ignore, _ := ignoreRaw.(string)
+
+	filter := persistfilter.New(allow, ignore)
+	if filter.Empty() {
+		filter = nil
+	}
+	persistFilter.Store(filter)
+	if appLogger != nil {
+		if filter == nil {
+			appLogger.Info("Discovery persistence filters cleared")
+		} else {
+			appLogger.Info("Discovery persistence filters updated",
+				"allow_patterns", len(filter.AllowPatterns),
+				"ignored_sys_object_ids", len(filter.IgnoreSysObjectIDs))
+		}
+	}
+}
+
+// shouldPersistDevice reports whether a discovered device passes the
+// configured persistence filters.
+func shouldPersistDevice(pi agent.PrinterInfo) bool {
+	ok, reason := persistFilter.Load().Allows(pi.Manufacturer, pi.Model, pi.SysObjectID)
+	if !ok && appLogger != nil {
+		appLogger.Debug("Discovered device not persisted",
+			"ip", pi.IP, "serial", pi.Serial, "manufacturer", pi.Manufacturer, "model", pi.Model, "reason", reason)
+	}
+	return ok
+}
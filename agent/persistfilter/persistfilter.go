@@ -0,0 +1,82 @@
+// Package persistfilter decides which discovered devices are persisted. Sites
+// with many non-printer SNMP devices (UPSes, cameras, switches) use it to keep
+// discovery noise out of the device database.
+package persistfilter
+
+import (
+	"path"
+	"strings"
+)
+
+// Filter holds the persistence allow list and sysObjectID deny list. The zero
+// value (and a nil *Filter) allows everything.
+type Filter struct {
+	// AllowPatterns are case-insensitive glob patterns matched against the
+	// manufacturer, the model, or "manufacturer model". When non-empty only
+	// matching devices are persisted.
+	AllowPatterns []string
+	// IgnoreSysObjectIDs are OID prefixes; devices whose sysObjectID equals or
+	// falls under one of them are never persisted.
+	IgnoreSysObjectIDs []string
+}
+
+// New builds a Filter from newline/comma separated settings text. Blank
+// entries and lines starting with '#' are ignored.
+func New(allowText, ignoreSysObjectIDsText string) *Filter {
+	f := &Filter{AllowPatterns: splitList(allowText)}
+	for _, oid := range splitList(ignoreSysObjectIDsText) {
+		f.IgnoreSysObjectIDs = append(f.IgnoreSysObjectIDs, strings.Trim(oid, "."))
+	}
+	return f
+}
+
+// Empty reports whether the filter has no rules.
+func (f *Filter) Empty() bool {
+	return f == nil || (len(f.AllowPatterns) == 0 && len(f.IgnoreSysObjectIDs) == 0)
+}
+
+// Allows reports whether a device should be persisted. When it should not,
+// the returned reason names the rule responsible.
+func (f *Filter) Allows(manufacturer, model, sysObjectID string) (bool, string) {
+	if f.Empty() {
+		return true, ""
+	}
+	soid := strings.Trim(strings.TrimSpace(sysObjectID), ".")
+	if soid != "" {
+		for _, prefix := range f.IgnoreSysObjectIDs {
+			if soid == prefix || strings.HasPrefix(soid, prefix+".") {
+				return false, "sysObjectID " + soid + " ignored by " + prefix
+			}
+		}
+	}
+	if len(f.AllowPatterns) == 0 {
+		return true, ""
+	}
+	manufacturer = strings.ToLower(strings.TrimSpace(manufacturer))
+	model = strings.ToLower(strings.TrimSpace(model))
+	candidates := []string{manufacturer, model, strings.TrimSpace(manufacturer + " " + model)}
+	for _, pattern := range f.AllowPatterns {
+		pattern = strings.ToLower(pattern)
+		for _, c := range candidates {
+			if c == "" {
+				continue
+			}
+			if ok, err := path.Match(pattern, c); err == nil && ok {
+				return true, ""
+			}
+		}
+	}
+	return false, "manufacturer/model not in allow list"
+}
+
+func splitList(text string) []string {
+	var out []string
+	for _, line := range strings.FieldsFunc(text, func(r rune) bool { return r == '\n' || r == ',' }) {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		out = append(out, line)
+	}
+	return out
+}
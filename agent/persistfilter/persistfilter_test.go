@@ -0,0 +1,58 @@
+package persistfilter
+
+import "testing"
+
+func TestFilterAllows(t *testing.T) {
+	t.Parallel()
+
+	f := New("HP\n# printers only\nkyocera*, *LaserJet*", "1.3.6.1.4.1.318\n.1.3.6.1.4.1.9.")
+
+	tests := []struct {
+		name                      string
+		manufacturer, model, soid string
+		want                      bool
+	}{
+		{"manufacturer exact", "hp", "OfficeJet Pro", "1.3.6.1.4.1.11.2.3.9.1", true},
+		{"manufacturer glob", "Kyocera", "TASKalfa 3252ci", "", true},
+		{"model glob", "Unknown", "Color LaserJet M479", "", true},
+		{"not allowed", "Axis", "P3245 Camera", "1.3.6.1.4.1.368", false},
+		{"empty identity", "", "", "", false},
+		{"ignored sysObjectID wins", "HP", "UPS", "1.3.6.1.4.1.318.1.3.27", false},
+		{"ignored with leading dot", "HP", "Switch", ".1.3.6.1.4.1.9.1.1", false},
+		{"prefix boundary", "HP", "LaserJet", "1.3.6.1.4.1.3180", true},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			got, reason := f.Allows(tt.manufacturer, tt.model, tt.soid)
+			if got != tt.want {
+				t.Fatalf("Allows(%q, %q, %q) = %v (%s), want %v", tt.manufacturer, tt.model, tt.soid, got, reason, tt.want)
+			}
+			if !got && reason == "" {
+				t.Fatal("expected a reason for rejected device")
+			}
+		})
+	}
+}
+
+func TestFilterEmpty(t *testing.T) {
+	t.Parallel()
+
+	var nilFilter *Filter
+	if !nilFilter.Empty() {
+		t.Fatal("nil filter should be empty")
+	}
+	if ok, _ := nilFilter.Allows("", "", ""); !ok {
+		t.Fatal("nil filter should allow everything")
+	}
+	f := New(" \n# comment\n", "")
+	if !f.Empty() {
+		t.Fatalf("expected empty filter, got %+v", f)
+	}
+
+	denyOnly := New("", "1.3.6.1.4.1.318")
+	if ok, _ := denyOnly.Allows("", "", ""); !ok {
+		t.Fatal("deny-only filter should allow devices without sysObjectID")
+	}
+}
@@ -0,0 +1,441 @@
+// Package plugins runs site-specific enrichment plugins after discovery and
+// metrics collection. A plugin is an executable (or a WASI module run through
+// a WebAssembly runtime) that receives the device as JSON on stdin and prints
+// a JSON object of fields to merge into the device's RawData.
+//
+// Only plugins listed in the agent config file run, each under a timeout and
+// with a minimal environment. A plugin may be pinned to the SHA-256 of its
+// file so a replaced binary is refused instead of executed.
+package plugins
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Stage is the point in the collection pipeline where a plugin runs.
+type Stage string
+
+const (
+	// StageDiscovery runs after a device is discovered, before it is saved.
+	StageDiscovery Stage = "discovery"
+	// StageMetrics runs after a metrics snapshot is collected for a device.
+	StageMetrics Stage = "metrics"
+)
+
+const (
+	// DefaultTimeout bounds a plugin run when none is configured.
+	DefaultTimeout = 5 * time.Second
+	// MaxTimeout caps configured timeouts so a plugin cannot stall scans.
+	MaxTimeout = 60 * time.Second
+	// DefaultWASMRuntime runs .wasm plugins: `<runtime> run <module> <args>`.
+	DefaultWASMRuntime = "wasmtime"
+
+	maxOutputBytes = 1 << 20
+	maxStderrBytes = 4 << 10
+	maxFields      = 64
+)
+
+// ErrHashMismatch is returned (wrapped) when a pinned plugin file changed.
+var ErrHashMismatch = errors.New("plugin file does not match its pinned sha256")
+
+// Plugin describes one allowlisted plugin.
+type Plugin struct {
+	Name string
+	// Path is the absolute path to the executable or .wasm module.
+	Path string
+	Args []string
+	// SHA256 optionally pins the file contents (hex).
+	SHA256  string
+	Timeout time.Duration
+	// Stages defaults to discovery only.
+	Stages []Stage
+	// Fields limits the RawData keys the plugin may set; empty allows any.
+	Fields []string
+}
+
+// Config is the set of plugins and shared limits.
+type Config struct {
+	Plugins []Plugin
+	// WASMRuntime is the command used for .wasm plugins.
+	WASMRuntime string
+	// MaxConcurrent bounds plugin processes running at once (default 4).
+	MaxConcurrent int
+}
+
+// Status reports a plugin and its recent runs.
+type Status struct {
+	Name         string    `json:"name"`
+	Path         string    `json:"path"`
+	WASM         bool      `json:"wasm"`
+	Stages       []Stage   `json:"stages"`
+	Fields       []string  `json:"fields,omitempty"`
+	TimeoutMs    int64     `json:"timeout_ms"`
+	Pinned       bool      `json:"pinned"`
+	Runs         int64     `json:"runs"`
+	Failures     int64     `json:"failures"`
+	LastRunAt    time.Time `json:"last_run_at,omitempty"`
+	LastDuration int64     `json:"last_duration_ms,omitempty"`
+	LastError    string    `json:"last_error,omitempty"`
+}
+
+type plugin struct {
+	Plugin
+	wasm   bool
+	fields map[string]struct{}
+
+	mu       sync.Mutex
+	status   Status
+	hashSize int64
+	hashMod  time.Time
+}
+
+// Runner runs the configured plugins. A nil Runner runs nothing.
+type Runner struct {
+	plugins     []*plugin
+	wasmRuntime string
+	sem         chan struct{}
+}
+
+// New validates cfg and returns a Runner for the valid plugins. Invalid
+// plugins are reported and left out.
+func New(cfg Config) (*Runner, []error) {
+	var errs []error
+	r := &Runner{wasmRuntime: strings.TrimSpace(cfg.WASMRuntime)}
+	if r.wasmRuntime == "" {
+		r.wasmRuntime = DefaultWASMRuntime
+	}
+	max := cfg.MaxConcurrent
+	if max <= 0 {
+		max = 4
+	}
+	r.sem = make(chan struct{}, max)
+	seen := make(map[string]bool)
+	for _, p := range cfg.Plugins {
+		pl, err := newPlugin(p)
+		if err == nil && seen[pl.Name] {
+			err = fmt.Errorf("plugin %q: duplicate name", pl.Name)
+		}
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		seen[pl.Name] = true
+		r.plugins = append(r.plugins, pl)
+	}
+	return r, errs
+}
+
+func newPlugin(p Plugin) (*plugin, error) {
+	p.Name = strings.TrimSpace(p.Name)
+	p.Path = strings.TrimSpace(p.Path)
+	if p.Name == "" {
+		p.Name = strings.TrimSuffix(filepath.Base(p.Path), filepath.Ext(p.Path))
+	}
+	if p.Path == "" || !filepath.IsAbs(p.Path) {
+		return nil, fmt.Errorf("plugin %q: path must be absolute", p.Name)
+	}
+	info, err := os.Stat(p.Path)
+	if err != nil {
+		return nil, fmt.Errorf("plugin %q: %w", p.Name, err)
+	}
+	if !info.Mode().IsRegular() {
+		return nil, fmt.Errorf("plugin %q: %s is not a regular file", p.Name, p.Path)
+	}
+	wasm := strings.EqualFold(filepath.Ext(p.Path), ".wasm")
+	if !wasm && runtime.GOOS != "windows" && info.Mode().Perm()&0o111 == 0 {
+		return nil, fmt.Errorf("plugin %q: %s is not executable", p.Name, p.Path)
+	}
+	p.SHA256 = strings.ToLower(strings.TrimSpace(p.SHA256))
+	if p.SHA256 != "" {
+		if b, err := hex.DecodeString(p.SHA256); err != nil || len(b) != sha256.Size {
+			return nil, fmt.Errorf("plugin %q: sha256 must be 64 hex characters", p.Name)
+		}
+	}
+	if p.Timeout <= 0 {
+		p.Timeout = DefaultTimeout
+	}
+	if p.Timeout > MaxTimeout {
+		p.Timeout = MaxTimeout
+	}
+	if len(p.Stages) == 0 {
+		p.Stages = []Stage{StageDiscovery}
+	}
+	for _, s := range p.Stages {
+		if s != StageDiscovery && s != StageMetrics {
+			return nil, fmt.Errorf("plugin %q: unknown stage %q", p.Name, s)
+		}
+	}
+	pl := &plugin{Plugin: p, wasm: wasm}
+	if len(p.Fields) > 0 {
+		pl.fields = make(map[string]struct{}, len(p.Fields))
+		for _, f := range p.Fields {
+			pl.fields[strings.TrimSpace(f)] = struct{}{}
+		}
+	}
+	pl.status = Status{
+		Name:      p.Name,
+		Path:      p.Path,
+		WASM:      wasm,
+		Stages:    p.Stages,
+		Fields:    p.Fields,
+		TimeoutMs: p.Timeout.Milliseconds(),
+		Pinned:    p.SHA256 != "",
+	}
+	return pl, nil
+}
+
+func (p *plugin) runsAt(stage Stage) bool {
+	for _, s := range p.Stages {
+		if s == stage {
+			return true
+		}
+	}
+	return false
+}
+
+// Active reports whether any plugin runs at stage.
+func (r *Runner) Active(stage Stage) bool {
+	if r == nil {
+		return false
+	}
+	for _, p := range r.plugins {
+		if p.runsAt(stage) {
+			return true
+		}
+	}
+	return false
+}
+
+// Statuses returns every plugin with its run counters, sorted by name.
+func (r *Runner) Statuses() []Status {
+	if r == nil {
+		return nil
+	}
+	out := make([]Status, 0, len(r.plugins))
+	for _, p := range r.plugins {
+		p.mu.Lock()
+		out = append(out, p.status)
+		p.mu.Unlock()
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+	return out
+}
+
+// Run sends input as JSON to each plugin of stage, in config order, and
+// returns the merged fields. Later plugins override keys set by earlier ones.
+// A failing plugin contributes nothing; its error is returned and the others
+// still run.
+func (r *Runner) Run(ctx context.Context, stage Stage, input interface{}) (map[string]interface{}, []error) {
+	if !r.Active(stage) {
+		return nil, nil
+	}
+	payload, err := json.Marshal(input)
+	if err != nil {
+		return nil, []error{fmt.Errorf("encode plugin input: %w", err)}
+	}
+	var errs []error
+	merged := make(map[string]interface{})
+	for _, p := range r.plugins {
+		if !p.runsAt(stage) {
+			continue
+		}
+		fields, err := r.runOne(ctx, p, stage, payload)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("plugin %q: %w", p.Name, err))
+			continue
+		}
+		for k, v := range fields {
+			merged[k] = v
+		}
+	}
+	return merged, errs
+}
+
+func (r *Runner) runOne(ctx context.Context, p *plugin, stage Stage, payload []byte) (map[string]interface{}, error) {
+	select {
+	case r.sem <- struct{}{}:
+		defer func() { <-r.sem }()
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	start := time.Now()
+	fields, err := r.exec(ctx, p, stage, payload)
+	p.mu.Lock()
+	p.status.Runs++
+	p.status.LastRunAt = start.UTC()
+	p.status.LastDuration = time.Since(start).Milliseconds()
+	p.status.LastError = ""
+	if err != nil {
+		p.status.Failures++
+		p.status.LastError = err.Error()
+	}
+	p.mu.Unlock()
+	return fields, err
+}
+
+func (r *Runner) exec(ctx context.Context, p *plugin, stage Stage, payload []byte) (map[string]interface{}, error) {
+	if err := p.verify(); err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithTimeout(ctx, p.Timeout)
+	defer cancel()
+
+	name, args := p.Path, p.Args
+	if p.wasm {
+		name, args = r.wasmRuntime, append([]string{"run", p.Path}, p.Args...)
+	}
+	cmd := exec.CommandContext(ctx, name, args...)
+	cmd.Dir = filepath.Dir(p.Path)
+	cmd.Env = pluginEnv(stage)
+	cmd.Stdin = bytes.NewReader(payload)
+	stdout := &limitedBuffer{max: maxOutputBytes}
+	stderr := &limitedBuffer{max: maxStderrBytes}
+	cmd.Stdout, cmd.Stderr = stdout, stderr
+	cmd.WaitDelay = time.Second
+
+	err := cmd.Run()
+	if ctx.Err() == context.DeadlineExceeded {
+		return nil, fmt.Errorf("timed out after %s", p.Timeout)
+	}
+	if err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return nil, fmt.Errorf("%w: %s", err, msg)
+		}
+		return nil, err
+	}
+	if stdout.truncated {
+		return nil, fmt.Errorf("output exceeds %d bytes", maxOutputBytes)
+	}
+	return p.parse(stdout.Bytes())
+}
+
+// parse decodes the plugin's JSON object and keeps the allowed fields.
+func (p *plugin) parse(out []byte) (map[string]interface{}, error) {
+	out = bytes.TrimSpace(out)
+	if len(out) == 0 {
+		return nil, nil
+	}
+	var fields map[string]interface{}
+	if err := json.Unmarshal(out, &fields); err != nil {
+		return nil, fmt.Errorf("output is not a JSON object: %w", err)
+	}
+	kept := make(map[string]interface{}, len(fields))
+	var rejected []string
+	for k, v := range fields {
+		if !validFieldName(k) {
+			rejected = append(rejected, k)
+			continue
+		}
+		if p.fields != nil {
+			if _, ok := p.fields[k]; !ok {
+				rejected = append(rejected, k)
+				continue
+			}
+		}
+		kept[k] = v
+	}
+	if len(kept) > maxFields {
+		return nil, fmt.Errorf("returned %d fields; at most %d are accepted", len(kept), maxFields)
+	}
+	if len(rejected) > 0 && len(kept) == 0 {
+		sort.Strings(rejected)
+		return nil, fmt.Errorf("no allowed fields in output (rejected: %s)", strings.Join(rejected, ", "))
+	}
+	return kept, nil
+}
+
+// validFieldName accepts short snake_case style keys so plugin output cannot
+// produce keys that look like nested paths or collide with odd encodings.
+func validFieldName(k string) bool {
+	if k == "" || len(k) > 64 {
+		return false
+	}
+	for _, c := range k {
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9', c == '_', c == '-':
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// verify checks the pinned hash, re-reading the file only when its size or
+// modification time changed since the last successful check.
+func (p *plugin) verify() error {
+	info, err := os.Stat(p.Path)
+	if err != nil {
+		return err
+	}
+	if p.SHA256 == "" {
+		return nil
+	}
+	p.mu.Lock()
+	fresh := p.hashSize == info.Size() && p.hashMod.Equal(info.ModTime())
+	p.mu.Unlock()
+	if fresh {
+		return nil
+	}
+	f, err := os.Open(p.Path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return err
+	}
+	if hex.EncodeToString(h.Sum(nil)) != p.SHA256 {
+		return ErrHashMismatch
+	}
+	p.mu.Lock()
+	p.hashSize, p.hashMod = info.Size(), info.ModTime()
+	p.mu.Unlock()
+	return nil
+}
+
+// pluginEnv passes only what a process needs to start, so agent secrets in
+// the environment never reach a plugin.
+func pluginEnv(stage Stage) []string {
+	keep := []string{"PATH", "HOME", "TMPDIR", "TEMP", "TMP", "SYSTEMROOT", "WINDIR", "LANG"}
+	env := []string{"PRINTMASTER_PLUGIN_STAGE=" + string(stage)}
+	for _, k := range keep {
+		if v, ok := os.LookupEnv(k); ok {
+			env = append(env, k+"="+v)
+		}
+	}
+	return env
+}
+
+// limitedBuffer keeps the first max bytes written and drops the rest.
+type limitedBuffer struct {
+	bytes.Buffer
+	max       int
+	truncated bool
+}
+
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+	if room := b.max - b.Len(); room < len(p) {
+		b.truncated = true
+		if room > 0 {
+			b.Buffer.Write(p[:room])
+		}
+		return len(p), nil
+	}
+	return b.Buffer.Write(p)
+}
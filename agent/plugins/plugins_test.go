@@ -0,0 +1,129 @@
+package plugins
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+func writeScript(t *testing.T, body string) string {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("shell script plugins")
+	}
+	path := filepath.Join(t.TempDir(), "plugin.sh")
+	if err := os.WriteFile(path, []byte("#!/bin/sh\n"+body+"\n"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestRunMergesAllowedFields(t *testing.T) {
+	// Echo the stage and a value derived from stdin
+	path := writeScript(t, `read input
+case "$input" in *JPBCD12345*) serial=yes ;; *) serial=no ;; esac
+echo "{\"cost_center\":\"CC-$PRINTMASTER_PLUGIN_STAGE\",\"saw_serial\":\"$serial\",\"secret\":\"x\",\"a.b\":1}"`)
+	r, errs := New(Config{Plugins: []Plugin{{Name: "cmdb", Path: path, Fields: []string{"cost_center", "saw_serial", "a.b"}}}})
+	if len(errs) != 0 {
+		t.Fatal(errs)
+	}
+	if r.Active(StageMetrics) || !r.Active(StageDiscovery) {
+		t.Fatal("plugins default to the discovery stage only")
+	}
+	fields, errs := r.Run(context.Background(), StageDiscovery, map[string]string{"serial": "JPBCD12345"})
+	if len(errs) != 0 {
+		t.Fatal(errs)
+	}
+	if fields["cost_center"] != "CC-discovery" || fields["saw_serial"] != "yes" {
+		t.Fatalf("unexpected fields: %v", fields)
+	}
+	if _, ok := fields["secret"]; ok {
+		t.Fatal("field outside the allowlist was kept")
+	}
+	if _, ok := fields["a.b"]; ok {
+		t.Fatal("invalid field name was kept")
+	}
+	st := r.Statuses()
+	if len(st) != 1 || st[0].Runs != 1 || st[0].Failures != 0 {
+		t.Fatalf("unexpected status: %+v", st)
+	}
+}
+
+func TestRunTimeoutAndFailures(t *testing.T) {
+	slow := writeScript(t, "sleep 5\necho '{\"late\":true}'")
+	bad := writeScript(t, "echo 'not json'")
+	failing := writeScript(t, "echo boom >&2\nexit 3")
+	r, errs := New(Config{Plugins: []Plugin{
+		{Name: "slow", Path: slow, Timeout: 200 * time.Millisecond},
+		{Name: "bad", Path: bad},
+		{Name: "failing", Path: failing},
+	}})
+	if len(errs) != 0 {
+		t.Fatal(errs)
+	}
+	start := time.Now()
+	fields, errs := r.Run(context.Background(), StageDiscovery, map[string]string{})
+	if time.Since(start) > 3*time.Second {
+		t.Fatal("timed out plugin was not killed")
+	}
+	if len(fields) != 0 || len(errs) != 3 {
+		t.Fatalf("fields %v errs %v", fields, errs)
+	}
+	if !strings.Contains(errs[0].Error(), "timed out") || !strings.Contains(errs[2].Error(), "boom") {
+		t.Fatalf("unexpected errors: %v", errs)
+	}
+}
+
+func TestPinnedHash(t *testing.T) {
+	path := writeScript(t, `echo '{"site":"hq"}'`)
+	data, _ := os.ReadFile(path)
+	sum := sha256.Sum256(data)
+	r, errs := New(Config{Plugins: []Plugin{{Name: "pinned", Path: path, SHA256: hex.EncodeToString(sum[:])}}})
+	if len(errs) != 0 {
+		t.Fatal(errs)
+	}
+	if fields, errs := r.Run(context.Background(), StageDiscovery, nil); len(errs) != 0 || fields["site"] != "hq" {
+		t.Fatalf("pinned plugin: %v %v", fields, errs)
+	}
+	// Replacing the file refuses the plugin
+	if err := os.WriteFile(path, []byte("#!/bin/sh\necho '{\"site\":\"evil\"}'\n"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	future := time.Now().Add(time.Minute)
+	os.Chtimes(path, future, future)
+	if _, errs := r.Run(context.Background(), StageDiscovery, nil); len(errs) != 1 || !errors.Is(errs[0], ErrHashMismatch) {
+		t.Fatalf("expected hash mismatch, got %v", errs)
+	}
+}
+
+func TestNewRejectsInvalidPlugins(t *testing.T) {
+	path := writeScript(t, "true")
+	noExec := filepath.Join(t.TempDir(), "noexec.sh")
+	os.WriteFile(noExec, []byte("#!/bin/sh\n"), 0o644)
+	r, errs := New(Config{Plugins: []Plugin{
+		{Name: "relative", Path: "plugin.sh"},
+		{Name: "missing", Path: filepath.Join(t.TempDir(), "missing")},
+		{Name: "noexec", Path: noExec},
+		{Name: "stage", Path: path, Stages: []Stage{"upload"}},
+		{Name: "hash", Path: path, SHA256: "abc"},
+		{Name: "ok", Path: path, Stages: []Stage{StageMetrics}},
+		{Name: "ok", Path: path},
+	}})
+	if len(errs) != 6 {
+		t.Fatalf("expected 6 errors, got %v", errs)
+	}
+	if st := r.Statuses(); len(st) != 1 || st[0].Name != "ok" || !r.Active(StageMetrics) {
+		t.Fatalf("unexpected plugins: %+v", st)
+	}
+	var nilRunner *Runner
+	if fields, errs := nilRunner.Run(context.Background(), StageDiscovery, nil); fields != nil || errs != nil {
+		t.Fatal("nil runner should do nothing")
+	}
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"printmaster/agent/scanner"
+	"printmaster/agent/storage"
+)
+
+// portOverridesKey stores per-device SNMP/HTTP port overrides for printers
+// reachable only through forwarded ports, keyed by device IP.
+const portOverridesKey = "device_port_overrides"
+
+var (
+	portOverridesMu    sync.Mutex
+	portOverridesStore storage.AgentConfigStore
+)
+
+// initPortOverrides restores saved port overrides so the scanner, metrics
+// collector and proxy use them from the first request.
+func initPortOverrides(store storage.AgentConfigStore) {
+	portOverridesMu.Lock()
+	portOverridesStore = store
+	portOverridesMu.Unlock()
+	if store == nil {
+		return
+	}
+	var saved map[string]scanner.PortOverride
+	if err := store.GetConfigValue(portOverridesKey, &saved); err == nil && len(saved) > 0 {
+		scanner.LoadPortOverrides(saved)
+		if appLogger != nil {
+			appLogger.Info("Loaded device port overrides", "devices", len(saved))
+		}
+	}
+}
+
+// rememberPortOverrides applies overrides from explicit host:port targets and
+// persists them when anything changed.
+func rememberPortOverrides(overrides map[string]scanner.PortOverride) {
+	changed := false
+	for ip, p := range overrides {
+		if scanner.SetPortOverride(ip, p) {
+			changed = true
+		}
+	}
+	if changed {
+		savePortOverrides()
+	}
+}
+
+func savePortOverrides() {
+	portOverridesMu.Lock()
+	defer portOverridesMu.Unlock()
+	if portOverridesStore == nil {
+		return
+	}
+	if err := portOverridesStore.SetConfigValue(portOverridesKey, scanner.PortOverrides()); err != nil && appLogger != nil {
+		appLogger.Warn("Failed to save device port overrides", "error", err)
+	}
+}
+
+type portOverrideRequest struct {
+	IP       string `json:"ip,omitempty"`
+	Serial   string `json:"serial,omitempty"`
+	SNMPPort int    `json:"snmp_port"`
+	HTTPPort int    `json:"http_port"`
+}
+
+// registerPortOverrideHandlers exposes per-device port overrides.
+func registerPortOverrideHandlers() {
+	// GET  /api/devices/ports - list overrides keyed by IP
+	// POST /api/devices/ports - set overrides for a device; zero ports clear them
+	http.HandleFunc("/api/devices/ports", func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(scanner.PortOverrides())
+		case http.MethodPost:
+			var req portOverrideRequest
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+				http.Error(w, "bad json", http.StatusBadRequest)
+				return
+			}
+			ip := strings.TrimSpace(req.IP)
+			if ip == "" && req.Serial != "" && deviceStore != nil {
+				ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+				dev, err := deviceStore.Get(ctx, req.Serial)
+				cancel()
+				if err != nil {
+					http.Error(w, "device not found", http.StatusNotFound)
+					return
+				}
+				ip = dev.IP
+			}
+			if parsed := net.ParseIP(ip); parsed == nil || parsed.To4() == nil {
+				http.Error(w, "ip or serial of an IPv4 device required", http.StatusBadRequest)
+				return
+			}
+			if req.SNMPPort < 0 || req.SNMPPort > 65535 || req.HTTPPort < 0 || req.HTTPPort > 65535 {
+				http.Error(w, "ports must be between 1 and 65535, or 0 for the default", http.StatusBadRequest)
+				return
+			}
+			override := scanner.PortOverride{SNMPPort: req.SNMPPort, HTTPPort: req.HTTPPort}
+			if scanner.SetPortOverride(ip, override) {
+				savePortOverrides()
+				if appLogger != nil {
+					appLogger.Info("Device port override updated", "ip", ip, "snmp_port", req.SNMPPort, "http_port", req.HTTPPort)
+				}
+			}
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(map[string]interface{}{"ip": ip, "snmp_port": req.SNMPPort, "http_port": req.HTTPPort})
+		default:
+			http.Error(w, "GET or POST only", http.StatusMethodNotAllowed)
+		}
+	})
+}
+
+// deviceWebUIURL returns the URL used to proxy a device's web UI: its
+// web_ui_url, or http://<ip>, with a forwarded HTTP port applied when the
+// URL points at the device IP without an explicit port.
+func deviceWebUIURL(device *storage.Device) string {
+	if device.WebUIURL == "" {
+		return "http://" + scanner.HTTPHost(device.IP)
+	}
+	u, err := url.Parse(device.WebUIURL)
+	if err != nil || u.Port() != "" || u.Hostname() != device.IP {
+		return device.WebUIURL
+	}
+	u.Host = scanner.HTTPHost(device.IP)
+	return u.String()
+}
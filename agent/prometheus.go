@@ -0,0 +1,261 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"printmaster/agent/agent"
+	"printmaster/agent/storage"
+	"printmaster/common/promexport"
+)
+
+// promStats counts agent activity between Prometheus scrapes. Device values
+// are read from the store on each scrape.
+type promStats struct {
+	mu                 sync.Mutex
+	collections        map[string]float64 // result -> count
+	collectionDuration float64            // Seconds, last metrics rescan
+	scans              map[string]float64 // status -> count
+	scanDuration       float64            // Seconds, last discovery scan
+	deviceUp           map[string]bool    // serial -> last collection succeeded
+}
+
+var agentPromStats = newPromStats()
+
+func newPromStats() *promStats {
+	return &promStats{
+		collections: make(map[string]float64),
+		scans:       make(map[string]float64),
+		deviceUp:    make(map[string]bool),
+	}
+}
+
+// recordCollection counts one SNMP metrics collection for a device.
+func (s *promStats) recordCollection(serial string, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	result := "ok"
+	if err != nil {
+		result = "error"
+	}
+	s.collections[result]++
+	if serial != "" {
+		s.deviceUp[serial] = err == nil
+	}
+}
+
+// recordCollectionRun records how long a metrics rescan of all devices took.
+func (s *promStats) recordCollectionRun(d time.Duration) {
+	s.mu.Lock()
+	s.collectionDuration = d.Seconds()
+	s.mu.Unlock()
+}
+
+// recordScan counts a finished discovery scan.
+func (s *promStats) recordScan(status string, d time.Duration) {
+	s.mu.Lock()
+	s.scans[status]++
+	s.scanDuration = d.Seconds()
+	s.mu.Unlock()
+}
+
+// forget drops a deleted device.
+func (s *promStats) forget(serial string) {
+	s.mu.Lock()
+	delete(s.deviceUp, serial)
+	s.mu.Unlock()
+}
+
+// families builds the agent's internal metric families.
+func (s *promStats) families() []*promexport.Family {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	collections := promexport.NewCounter("printmaster_agent_snmp_collections_total",
+		"SNMP metrics collections by result (ok or error).")
+	for _, result := range []string{"ok", "error"} {
+		collections.Add(s.collections[result], "result", result)
+	}
+	collectionDuration := promexport.NewGauge("printmaster_agent_metrics_collection_duration_seconds",
+		"Duration of the last metrics collection across all devices.")
+	collectionDuration.Add(s.collectionDuration)
+	scans := promexport.NewCounter("printmaster_agent_scans_total",
+		"Discovery scans by status (completed or failed).")
+	for _, status := range []string{storage.ScanRunCompleted, storage.ScanRunFailed} {
+		scans.Add(s.scans[status], "status", status)
+	}
+	scanDuration := promexport.NewGauge("printmaster_agent_scan_duration_seconds",
+		"Duration of the last discovery scan.")
+	scanDuration.Add(s.scanDuration)
+	return []*promexport.Family{collections, collectionDuration, scans, scanDuration}
+}
+
+// up reports whether the last collection from serial succeeded, and whether
+// there was one since the agent started.
+func (s *promStats) up(serial string) (bool, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	up, ok := s.deviceUp[serial]
+	return up, ok
+}
+
+// tonerPercent converts a stored toner level; negative values are SNMP
+// "unknown" markers and are skipped.
+func tonerPercent(v interface{}) (float64, bool) {
+	var f float64
+	switch n := v.(type) {
+	case int:
+		f = float64(n)
+	case int64:
+		f = float64(n)
+	case float64:
+		f = n
+	default:
+		return 0, false
+	}
+	return f, f >= 0 && f <= 100
+}
+
+// deviceFamilies builds per-device gauges from the visible devices and their
+// latest metrics.
+func deviceFamilies(ctx context.Context, store storage.DeviceStore, stats *promStats) ([]*promexport.Family, error) {
+	visible := true
+	devices, err := store.List(ctx, storage.DeviceFilter{Visible: &visible})
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(devices, func(i, j int) bool { return devices[i].Serial < devices[j].Serial })
+
+	info := promexport.NewGauge("printmaster_device_info", "Device identity; always 1.")
+	up := promexport.NewGauge("printmaster_device_up",
+		"1 if the last SNMP metrics collection from the device succeeded, 0 if it failed.")
+	lastSeen := promexport.NewGauge("printmaster_device_last_seen_timestamp_seconds",
+		"Unix time the device last answered.")
+	metricsTime := promexport.NewGauge("printmaster_device_metrics_timestamp_seconds",
+		"Unix time of the device's latest metrics snapshot.")
+	pages := promexport.NewGauge("printmaster_device_page_count", "Total page count.")
+	colorPages := promexport.NewGauge("printmaster_device_color_page_count", "Color page count.")
+	monoPages := promexport.NewGauge("printmaster_device_mono_page_count", "Monochrome page count.")
+	scanCount := promexport.NewGauge("printmaster_device_scan_count", "Scan count.")
+	toner := promexport.NewGauge("printmaster_device_toner_level_percent", "Toner remaining, in percent.")
+
+	for _, d := range devices {
+		info.Add(1, "serial", d.Serial, "ip", d.IP, "manufacturer", d.Manufacturer, "model", d.Model)
+		if ok, known := stats.up(d.Serial); known {
+			up.Add(boolValue(ok), "serial", d.Serial)
+		}
+		if !d.LastSeen.IsZero() {
+			lastSeen.Add(float64(d.LastSeen.Unix()), "serial", d.Serial)
+		}
+		m, err := store.GetLatestMetrics(ctx, d.Serial)
+		if err != nil || m == nil {
+			continue
+		}
+		metricsTime.Add(float64(m.Timestamp.Unix()), "serial", d.Serial)
+		pages.Add(float64(m.PageCount), "serial", d.Serial)
+		colorPages.Add(float64(m.ColorPages), "serial", d.Serial)
+		monoPages.Add(float64(m.MonoPages), "serial", d.Serial)
+		scanCount.Add(float64(m.ScanCount), "serial", d.Serial)
+		supplies := make([]string, 0, len(m.TonerLevels))
+		for supply := range m.TonerLevels {
+			supplies = append(supplies, supply)
+		}
+		sort.Strings(supplies)
+		for _, supply := range supplies {
+			if level, ok := tonerPercent(m.TonerLevels[supply]); ok {
+				toner.Add(level, "serial", d.Serial, "supply", supply)
+			}
+		}
+	}
+	return []*promexport.Family{info, up, lastSeen, metricsTime, pages, colorPages, monoPages, scanCount, toner}, nil
+}
+
+// uploadFamilies describes data waiting for the server and the connection.
+func uploadFamilies(ctx context.Context, store storage.DeviceStore, worker *UploadWorker) []*promexport.Family {
+	pending := promexport.NewGauge("printmaster_agent_upload_queue_depth",
+		"Items waiting to be uploaded to the server, by kind.")
+	if reads, ok := store.(storage.MeterReadStore); ok {
+		if list, err := reads.ListPendingMeterReads(ctx, 0); err == nil {
+			pending.Add(float64(len(list)), "kind", "meter_reads")
+		}
+	}
+	pending.Add(float64(len(agent.PendingUnknownDevices())), "kind", "unknown_devices")
+
+	if worker == nil {
+		return []*promexport.Family{pending}
+	}
+	health := worker.Status().Health
+	connected := promexport.NewGauge("printmaster_agent_server_up",
+		"1 if the last request to the server succeeded.")
+	connected.Add(boolValue(health.Status == agent.ServerHealthOK))
+	failures := promexport.NewGauge("printmaster_agent_server_consecutive_failures",
+		"Failed server requests since the last success.")
+	failures.Add(float64(health.ConsecutiveFailures))
+	return []*promexport.Family{pending, connected, failures}
+}
+
+func alertFamilies() []*promexport.Family {
+	open := promexport.NewGauge("printmaster_agent_alerts_open", "Open local alerts by type.")
+	counts := map[string]float64{}
+	for _, a := range localAlertEngine.Active() {
+		counts[a.Type]++
+	}
+	types := make([]string, 0, len(counts))
+	for t := range counts {
+		types = append(types, t)
+	}
+	sort.Strings(types)
+	for _, t := range types {
+		open.Add(counts[t], "type", t)
+	}
+	return []*promexport.Family{open}
+}
+
+func boolValue(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// handlePrometheusMetrics serves device and agent metrics for Prometheus.
+// GET /metrics/prometheus
+func handlePrometheusMetrics(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		http.Error(w, "GET only", http.StatusMethodNotAllowed)
+		return
+	}
+	ctx, cancel := context.WithTimeout(r.Context(), 15*time.Second)
+	defer cancel()
+
+	agentInfo := promexport.NewGauge("printmaster_agent_info", "Agent version and runtime environment; always 1.")
+	agentInfo.Add(1, "version", Version, "runtime_environment", string(agentRuntimeEnv))
+	families := []*promexport.Family{agentInfo}
+	families = append(families, agentPromStats.families()...)
+	families = append(families, alertFamilies()...)
+	if deviceStore != nil {
+		devices, err := deviceFamilies(ctx, deviceStore, agentPromStats)
+		if err != nil {
+			http.Error(w, "failed to list devices", http.StatusInternalServerError)
+			return
+		}
+		uploadWorkerMu.RLock()
+		worker := uploadWorker
+		uploadWorkerMu.RUnlock()
+		families = append(families, uploadFamilies(ctx, deviceStore, worker)...)
+		families = append(families, devices...)
+	}
+
+	write := promexport.Write
+	if promexport.WantsOpenMetrics(r.Header.Get("Accept")) {
+		w.Header().Set("Content-Type", promexport.ContentTypeOpenMetrics)
+		write = promexport.WriteOpenMetrics
+	} else {
+		w.Header().Set("Content-Type", promexport.ContentType)
+	}
+	if err := write(w, families); err != nil && appLogger != nil {
+		appLogger.Debug("Prometheus scrape aborted", "error", err)
+	}
+}
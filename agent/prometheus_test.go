@@ -0,0 +1,69 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"printmaster/agent/storage"
+	"printmaster/common/promexport"
+)
+
+func TestPrometheusDeviceFamilies(t *testing.T) {
+	store, err := storage.NewSQLiteStore(":memory:")
+	if err != nil {
+		t.Fatalf("NewSQLiteStore: %v", err)
+	}
+	defer store.Close()
+	ctx := context.Background()
+
+	for _, serial := range []string{"PM1", "PM2"} {
+		dev := &storage.Device{}
+		dev.Serial, dev.IP, dev.Manufacturer, dev.Model = serial, "10.0.0.1", "HP", "LaserJet"
+		dev.Visible, dev.IsSaved = true, true
+		if err := store.Create(ctx, dev); err != nil {
+			t.Fatalf("Create: %v", err)
+		}
+	}
+	snap := &storage.MetricsSnapshot{}
+	snap.Serial, snap.Timestamp, snap.PageCount, snap.ColorPages, snap.MonoPages = "PM1", time.Now(), 1200, 200, 1000
+	snap.TonerLevels = map[string]interface{}{"black": 35, "cyan": -2}
+	if err := store.SaveMetricsSnapshot(ctx, snap); err != nil {
+		t.Fatalf("SaveMetricsSnapshot: %v", err)
+	}
+
+	stats := newPromStats()
+	stats.recordCollection("PM1", nil)
+	stats.recordCollection("PM2", errors.New("timeout"))
+	stats.recordScan(storage.ScanRunCompleted, 2500*time.Millisecond)
+
+	families, err := deviceFamilies(ctx, store, stats)
+	if err != nil {
+		t.Fatalf("deviceFamilies: %v", err)
+	}
+	var b strings.Builder
+	if err := promexport.Write(&b, append(stats.families(), families...)); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	out := b.String()
+	for _, want := range []string{
+		`printmaster_device_info{serial="PM1",ip="10.0.0.1",manufacturer="HP",model="LaserJet"} 1`,
+		`printmaster_device_up{serial="PM1"} 1`,
+		`printmaster_device_up{serial="PM2"} 0`,
+		`printmaster_device_page_count{serial="PM1"} 1200`,
+		`printmaster_device_color_page_count{serial="PM1"} 200`,
+		`printmaster_device_toner_level_percent{serial="PM1",supply="black"} 35`,
+		`printmaster_agent_snmp_collections_total{result="error"} 1`,
+		`printmaster_agent_scans_total{status="completed"} 1`,
+		`printmaster_agent_scan_duration_seconds 2.5`,
+	} {
+		if !strings.Contains(out, want+"\n") {
+			t.Errorf("missing %q in:\n%s", want, out)
+		}
+	}
+	if strings.Contains(out, `supply="cyan"`) || strings.Contains(out, `printmaster_device_page_count{serial="PM2"}`) {
+		t.Errorf("unexpected samples in:\n%s", out)
+	}
+}
@@ -1,7 +1,6 @@
 package proxy
 
 import (
-	"crypto/tls"
 	"fmt"
 	"io"
 	"net/http"
@@ -12,6 +11,7 @@ import (
 	"sync"
 	"time"
 
+	"printmaster/agent/devicetls"
 	"printmaster/common/logger"
 )
 
@@ -78,14 +78,9 @@ func (e *EpsonLoginAdapter) Login(baseURL, username, password string, log *logge
 	client := &http.Client{
 		Jar:     jar,
 		Timeout: 10 * time.Second,
-		Transport: &http.Transport{
-			TLSClientConfig: &tls.Config{
-				// #nosec G402 -- InsecureSkipVerify intentionally enabled:
-				// Network printers commonly use self-signed SSL certificates.
-				// This adapter authenticates to printer web interfaces on local networks.
-				InsecureSkipVerify: true,
-			},
-		},
+		// Self-signed certificates and per-device TLS overrides are
+		// handled by devicetls.
+		Transport: devicetls.Transport(&http.Transport{}),
 		CheckRedirect: func(req *http.Request, via []*http.Request) error {
 			// Allow redirects but keep cookies
 			return nil
@@ -180,14 +175,9 @@ func (k *KyoceraLoginAdapter) Login(baseURL, username, password string, log *log
 	client := &http.Client{
 		Jar:     jar,
 		Timeout: 10 * time.Second,
-		Transport: &http.Transport{
-			TLSClientConfig: &tls.Config{
-				// #nosec G402 -- InsecureSkipVerify intentionally enabled:
-				// Network printers commonly use self-signed SSL certificates.
-				// This adapter authenticates to printer web interfaces on local networks.
-				InsecureSkipVerify: true,
-			},
-		},
+		// Self-signed certificates and per-device TLS overrides are
+		// handled by devicetls.
+		Transport: devicetls.Transport(&http.Transport{}),
 		CheckRedirect: func(req *http.Request, via []*http.Request) error {
 			// Follow redirects but keep cookies
 			return nil
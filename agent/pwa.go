@@ -0,0 +1,43 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+)
+
+// The embedded UI is installable as a progressive web app. The service
+// worker must be served from the root so its scope covers the whole UI; its
+// cache name carries the agent version so an upgrade replaces the cached
+// shell.
+
+func handleServiceWorker(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "only GET allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	content, err := webFS.ReadFile("web/sw.js")
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	script := strings.Replace(string(content), "{{VERSION}}", Version+"-"+GitCommit, 1)
+	w.Header().Set("Content-Type", "application/javascript; charset=utf-8")
+	// Browsers check for a new worker on navigation; never serve a stale one
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Service-Worker-Allowed", "/")
+	w.Write([]byte(script))
+}
+
+func handleWebManifest(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "only GET allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	content, err := webFS.ReadFile("web/manifest.webmanifest")
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	w.Header().Set("Content-Type", "application/manifest+json")
+	w.Write(content)
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestServiceWorkerAndManifest(t *testing.T) {
+	rec := httptest.NewRecorder()
+	handleServiceWorker(rec, httptest.NewRequest(http.MethodGet, "/sw.js", nil))
+	if rec.Code != http.StatusOK || !strings.HasPrefix(rec.Header().Get("Content-Type"), "application/javascript") {
+		t.Fatalf("sw.js: status %d, content type %q", rec.Code, rec.Header().Get("Content-Type"))
+	}
+	body := rec.Body.String()
+	if strings.Contains(body, "{{VERSION}}") || !strings.Contains(body, "'"+Version+"-"+GitCommit+"'") {
+		t.Error("service worker cache version was not filled in")
+	}
+	if rec.Header().Get("Service-Worker-Allowed") != "/" {
+		t.Error("service worker must be allowed the root scope")
+	}
+
+	rec = httptest.NewRecorder()
+	handleWebManifest(rec, httptest.NewRequest(http.MethodGet, "/manifest.webmanifest", nil))
+	var manifest struct {
+		StartURL string `json:"start_url"`
+		Display  string `json:"display"`
+		Icons    []struct {
+			Src string `json:"src"`
+		} `json:"icons"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &manifest); err != nil {
+		t.Fatalf("manifest is not valid JSON: %v", err)
+	}
+	if manifest.StartURL != "/" || manifest.Display != "standalone" || len(manifest.Icons) == 0 {
+		t.Fatalf("unexpected manifest: %+v", manifest)
+	}
+	for _, icon := range manifest.Icons {
+		if _, err := webFS.ReadFile("web/" + strings.TrimPrefix(icon.Src, "/static/")); err != nil {
+			t.Errorf("manifest icon %s is not embedded: %v", icon.Src, err)
+		}
+	}
+
+	// Browsers fetch both without the session cookie
+	auth := newAgentAuthManager(DefaultAgentConfig(), newAgentSessionManager())
+	auth.mode = "local"
+	for _, path := range []string{"/sw.js", "/manifest.webmanifest"} {
+		if !auth.shouldBypass(httptest.NewRequest(http.MethodGet, path, nil)) {
+			t.Errorf("%s should be public", path)
+		}
+	}
+}
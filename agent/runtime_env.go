@@ -0,0 +1,41 @@
+package main
+
+import (
+	"path/filepath"
+
+	"printmaster/agent/runtimeenv"
+	"printmaster/common/config"
+)
+
+// agentRuntimeEnv is where this process runs. It is detected once at startup
+// by runInteractive and reported in heartbeats, /health and /api/version.
+var agentRuntimeEnv = runtimeenv.Interactive
+
+// detectRuntimeEnvironment records the runtime environment of this process.
+func detectRuntimeEnvironment() runtimeenv.Environment {
+	agentRuntimeEnv = runtimeenv.Detect(runningAsService())
+	return agentRuntimeEnv
+}
+
+// agentDataDirectory returns the data directory for the runtime environment:
+// the system-wide directory for services and containers, the user's
+// directory for interactive runs.
+func agentDataDirectory() (string, error) {
+	return config.GetDataDirectory("agent", agentRuntimeEnv.SystemDirectories())
+}
+
+// agentLogDirectory returns where rotated log files are written. Containers
+// keep them on the data volume so they survive a restart of the container.
+func agentLogDirectory() string {
+	switch agentRuntimeEnv {
+	case runtimeenv.Interactive:
+		return "logs"
+	case runtimeenv.Container:
+		if dataDir, err := agentDataDirectory(); err == nil {
+			return filepath.Join(dataDir, "logs")
+		}
+		return "logs"
+	default:
+		return filepath.Dir(getServiceLogPath())
+	}
+}
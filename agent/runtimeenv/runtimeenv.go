@@ -0,0 +1,108 @@
+// Package runtimeenv detects how the agent process is being run: in a
+// container, under systemd, as a Windows service, under another service
+// manager, or interactively from a terminal. Data directories, logging sinks
+// and the environment reported to the server and UI follow from it.
+package runtimeenv
+
+import (
+	"os"
+	"runtime"
+	"strings"
+)
+
+// Environment is a detected runtime environment.
+type Environment string
+
+const (
+	Container      Environment = "container"
+	Systemd        Environment = "systemd"
+	WindowsService Environment = "windows_service"
+	Service        Environment = "service" // launchd, SysV init and other service managers
+	Interactive    Environment = "interactive"
+)
+
+// cgroupMarkers identify container runtimes in /proc/1/cgroup.
+var cgroupMarkers = []string{"docker", "kubepods", "containerd", "libpod", "lxc"}
+
+// probe abstracts the process environment so detection can be tested.
+type probe struct {
+	goos     string
+	getenv   func(string) string
+	exists   func(string) bool
+	readFile func(string) ([]byte, error)
+}
+
+// Detect returns the environment of the current process. service reports
+// whether a service manager started the process (see service.Interactive).
+// A container wins over everything else, since service managers inside
+// containers are rare and the container runtime owns the process lifecycle.
+func Detect(service bool) Environment {
+	return detect(service, probe{
+		goos:   runtime.GOOS,
+		getenv: os.Getenv,
+		exists: func(path string) bool {
+			_, err := os.Stat(path)
+			return err == nil
+		},
+		readFile: os.ReadFile,
+	})
+}
+
+func detect(service bool, p probe) Environment {
+	if inContainer(p) {
+		return Container
+	}
+	if !service {
+		return Interactive
+	}
+	switch p.goos {
+	case "windows":
+		return WindowsService
+	case "linux":
+		// systemd sets INVOCATION_ID for every unit it starts
+		if p.getenv("INVOCATION_ID") != "" || p.getenv("JOURNAL_STREAM") != "" || p.getenv("NOTIFY_SOCKET") != "" {
+			return Systemd
+		}
+	}
+	return Service
+}
+
+func inContainer(p probe) bool {
+	// DOCKER is set by the official image; container is set by podman and
+	// systemd-nspawn; Kubernetes injects its service host into every pod.
+	for _, key := range []string{"DOCKER", "container", "KUBERNETES_SERVICE_HOST"} {
+		if p.getenv(key) != "" {
+			return true
+		}
+	}
+	if p.goos != "linux" {
+		return false
+	}
+	if p.exists("/.dockerenv") || p.exists("/run/.containerenv") {
+		return true
+	}
+	data, err := p.readFile("/proc/1/cgroup")
+	if err != nil {
+		return false
+	}
+	cgroup := string(data)
+	for _, marker := range cgroupMarkers {
+		if strings.Contains(cgroup, marker) {
+			return true
+		}
+	}
+	return false
+}
+
+// SystemDirectories reports whether data should live in the system-wide
+// directory (/var/lib/printmaster, ProgramData) rather than the user's home.
+func (e Environment) SystemDirectories() bool {
+	return e != Interactive
+}
+
+// ConsoleLogging reports whether logs should also be written to stdout.
+// Container runtimes collect stdout; service managers would only duplicate
+// the agent's own rotated log files into syslog or the journal.
+func (e Environment) ConsoleLogging() bool {
+	return e == Interactive || e == Container
+}
@@ -0,0 +1,56 @@
+package runtimeenv
+
+import (
+	"errors"
+	"testing"
+)
+
+func fakeProbe(goos string, env map[string]string, files map[string]string) probe {
+	return probe{
+		goos:   goos,
+		getenv: func(key string) string { return env[key] },
+		exists: func(path string) bool {
+			_, ok := files[path]
+			return ok
+		},
+		readFile: func(path string) ([]byte, error) {
+			if data, ok := files[path]; ok {
+				return []byte(data), nil
+			}
+			return nil, errors.New("not found")
+		},
+	}
+}
+
+func TestDetect(t *testing.T) {
+	cases := []struct {
+		name    string
+		service bool
+		probe   probe
+		want    Environment
+	}{
+		{"terminal", false, fakeProbe("linux", nil, map[string]string{"/proc/1/cgroup": "0::/init.scope"}), Interactive},
+		{"official image", false, fakeProbe("linux", map[string]string{"DOCKER": "true"}, nil), Container},
+		{"dockerenv", false, fakeProbe("linux", nil, map[string]string{"/.dockerenv": ""}), Container},
+		{"kubernetes cgroup", true, fakeProbe("linux", nil, map[string]string{"/proc/1/cgroup": "12:pids:/kubepods/burstable/pod1"}), Container},
+		{"systemd unit", true, fakeProbe("linux", map[string]string{"INVOCATION_ID": "abc"}, nil), Systemd},
+		{"sysv init", true, fakeProbe("linux", nil, nil), Service},
+		{"windows service", true, fakeProbe("windows", nil, nil), WindowsService},
+		{"launchd", true, fakeProbe("darwin", nil, nil), Service},
+		{"desktop session", false, fakeProbe("linux", map[string]string{"JOURNAL_STREAM": "8:1"}, nil), Interactive},
+	}
+	for _, tc := range cases {
+		if got := detect(tc.service, tc.probe); got != tc.want {
+			t.Errorf("%s: got %s, want %s", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestDefaults(t *testing.T) {
+	if Interactive.SystemDirectories() || !Container.SystemDirectories() || !WindowsService.SystemDirectories() {
+		t.Fatal("only interactive runs should use per-user directories")
+	}
+	if !Container.ConsoleLogging() || Systemd.ConsoleLogging() || Service.ConsoleLogging() {
+		t.Fatal("console logging should be on for containers and off under service managers")
+	}
+}
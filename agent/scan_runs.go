@@ -0,0 +1,306 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"printmaster/agent/agent"
+	"printmaster/agent/storage"
+)
+
+// Sources recorded on scan runs.
+const (
+	scanRunSourceManual = "manual"
+	scanRunSourceAuto   = "auto"
+	scanRunSourceCLI    = "cli"
+)
+
+// scanRunStore returns the scan run store backing deviceStore, if any.
+func scanRunStore() storage.ScanRunStore {
+	store, _ := deviceStore.(storage.ScanRunStore)
+	return store
+}
+
+// recordScanRun stores the outcome of a discovery pass so it can be diffed
+// against other passes. Failures are logged and otherwise ignored.
+func recordScanRun(source, mode string, ranges []string, started time.Time, printers []agent.PrinterInfo, scanErr error) {
+	status := storage.ScanRunCompleted
+	if scanErr != nil {
+		status = storage.ScanRunFailed
+	}
+	agentPromStats.recordScan(status, time.Since(started))
+
+	store := scanRunStore()
+	if store == nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	run := &storage.ScanRun{
+		Source:     source,
+		Mode:       mode,
+		Ranges:     ranges,
+		StartedAt:  started,
+		FinishedAt: time.Now(),
+		Status:     status,
+		Devices:    make([]storage.ScanRunDevice, 0, len(printers)),
+	}
+	if scanErr != nil {
+		run.Error = scanErr.Error()
+	}
+	for _, pi := range printers {
+		d := storage.ScanRunDevice{
+			Serial:       pi.Serial,
+			IP:           pi.IP,
+			Manufacturer: pi.Manufacturer,
+			Model:        pi.Model,
+			Hostname:     pi.Hostname,
+			Firmware:     pi.Firmware,
+			MACAddress:   pi.MAC,
+		}
+		if pi.Serial != "" && deviceStore != nil {
+			if device, err := deviceStore.Get(ctx, pi.Serial); err == nil && device != nil {
+				d.ScanID = device.LastScanID
+			}
+		}
+		run.Devices = append(run.Devices, d)
+	}
+	if err := store.AddScanRun(ctx, run); err != nil {
+		appLogger.Warn("Failed to record scan run", "source", source, "error", err)
+	}
+}
+
+// scanFieldChange is a single field that differs between two scan runs.
+type scanFieldChange struct {
+	Field  string `json:"field"`
+	Before string `json:"before"`
+	After  string `json:"after"`
+}
+
+// scanDeviceChange describes a device seen by both runs with differing fields.
+type scanDeviceChange struct {
+	Key          string                `json:"key"`
+	Before       storage.ScanRunDevice `json:"before"`
+	After        storage.ScanRunDevice `json:"after"`
+	Changes      []scanFieldChange     `json:"changes"`
+	BeforeScanID int64                 `json:"before_scan_id,omitempty"`
+	AfterScanID  int64                 `json:"after_scan_id,omitempty"`
+}
+
+// scanRunDiff is the result of comparing a scan run against an earlier one.
+type scanRunDiff struct {
+	Run          *storage.ScanRun        `json:"run"`
+	Against      *storage.ScanRun        `json:"against"`
+	ScopeChanged bool                    `json:"scope_changed"`
+	Added        []storage.ScanRunDevice `json:"added"`
+	Removed      []storage.ScanRunDevice `json:"removed"`
+	Changed      []scanDeviceChange      `json:"changed"`
+	Unchanged    int                     `json:"unchanged"`
+}
+
+// diffScanRuns compares run against the baseline run. Devices are matched by
+// serial, or by IP when the serial is unknown.
+func diffScanRuns(run, against *storage.ScanRun) scanRunDiff {
+	diff := scanRunDiff{
+		Added:   []storage.ScanRunDevice{},
+		Removed: []storage.ScanRunDevice{},
+		Changed: []scanDeviceChange{},
+	}
+	before := make(map[string]storage.ScanRunDevice, len(against.Devices))
+	for _, d := range against.Devices {
+		before[d.Key()] = d
+	}
+	seen := make(map[string]bool, len(run.Devices))
+	for _, after := range run.Devices {
+		key := after.Key()
+		seen[key] = true
+		prev, ok := before[key]
+		if !ok {
+			diff.Added = append(diff.Added, after)
+			continue
+		}
+		changes := scanDeviceFieldChanges(prev, after)
+		if len(changes) == 0 {
+			diff.Unchanged++
+			continue
+		}
+		diff.Changed = append(diff.Changed, scanDeviceChange{
+			Key:          key,
+			Before:       prev,
+			After:        after,
+			Changes:      changes,
+			BeforeScanID: prev.ScanID,
+			AfterScanID:  after.ScanID,
+		})
+	}
+	for _, d := range against.Devices {
+		if !seen[d.Key()] {
+			diff.Removed = append(diff.Removed, d)
+		}
+	}
+	diff.ScopeChanged = !sameRanges(run.Ranges, against.Ranges)
+
+	// Strip device lists from the embedded runs; the diff carries what matters
+	diff.Run = scanRunSummary(run)
+	diff.Against = scanRunSummary(against)
+	return diff
+}
+
+func scanDeviceFieldChanges(before, after storage.ScanRunDevice) []scanFieldChange {
+	fields := []struct {
+		name          string
+		before, after string
+	}{
+		{"ip", before.IP, after.IP},
+		{"manufacturer", before.Manufacturer, after.Manufacturer},
+		{"model", before.Model, after.Model},
+		{"hostname", before.Hostname, after.Hostname},
+		{"firmware", before.Firmware, after.Firmware},
+		{"mac_address", before.MACAddress, after.MACAddress},
+	}
+	var changes []scanFieldChange
+	for _, f := range fields {
+		if !strings.EqualFold(strings.TrimSpace(f.before), strings.TrimSpace(f.after)) {
+			changes = append(changes, scanFieldChange{Field: f.name, Before: f.before, After: f.after})
+		}
+	}
+	return changes
+}
+
+func sameRanges(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	x := append([]string(nil), a...)
+	y := append([]string(nil), b...)
+	sort.Strings(x)
+	sort.Strings(y)
+	for i := range x {
+		if x[i] != y[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func scanRunSummary(run *storage.ScanRun) *storage.ScanRun {
+	summary := *run
+	summary.Devices = nil
+	return &summary
+}
+
+// registerScanRunHandlers exposes discovery run history and diffs.
+func registerScanRunHandlers() {
+	// GET /api/v1/scans?limit= - List discovery runs, newest first
+	http.HandleFunc("/api/v1/scans", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "GET only", http.StatusMethodNotAllowed)
+			return
+		}
+		store := scanRunStore()
+		if store == nil {
+			http.Error(w, "scan history not supported", http.StatusNotImplemented)
+			return
+		}
+		limit := 50
+		if l := r.URL.Query().Get("limit"); l != "" {
+			if parsed, err := strconv.Atoi(l); err == nil && parsed > 0 {
+				limit = parsed
+			}
+		}
+		ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+		defer cancel()
+		runs, err := store.ListScanRuns(ctx, limit)
+		if err != nil {
+			appLogger.Error("Failed to list scan runs", "error", err)
+			http.Error(w, "failed to list scans: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if runs == nil {
+			runs = []*storage.ScanRun{}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"scans": runs,
+			"count": len(runs),
+		})
+	})
+
+	// GET /api/v1/scans/{id} - A discovery run with the devices it found
+	// GET /api/v1/scans/{id}/diff?against={id2} - Devices added/removed/changed
+	// since run id2 (default: the previous completed run)
+	http.HandleFunc("/api/v1/scans/", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "GET only", http.StatusMethodNotAllowed)
+			return
+		}
+		store := scanRunStore()
+		if store == nil {
+			http.Error(w, "scan history not supported", http.StatusNotImplemented)
+			return
+		}
+		rest := strings.TrimPrefix(r.URL.Path, "/api/v1/scans/")
+		idPart, action, _ := strings.Cut(rest, "/")
+		id, err := strconv.ParseInt(idPart, 10, 64)
+		if err != nil || id <= 0 {
+			http.Error(w, "invalid scan id", http.StatusBadRequest)
+			return
+		}
+		if action != "" && action != "diff" {
+			http.NotFound(w, r)
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+		defer cancel()
+		run, err := store.GetScanRun(ctx, id)
+		if err != nil {
+			http.Error(w, "failed to load scan: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if run == nil {
+			http.Error(w, "scan not found", http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		if action == "" {
+			json.NewEncoder(w).Encode(run)
+			return
+		}
+
+		var against *storage.ScanRun
+		if a := r.URL.Query().Get("against"); a != "" {
+			againstID, err := strconv.ParseInt(a, 10, 64)
+			if err != nil || againstID <= 0 {
+				http.Error(w, "invalid against scan id", http.StatusBadRequest)
+				return
+			}
+			against, err = store.GetScanRun(ctx, againstID)
+			if err != nil {
+				http.Error(w, "failed to load scan: "+err.Error(), http.StatusInternalServerError)
+				return
+			}
+		} else {
+			against, err = store.PreviousScanRun(ctx, id)
+			if err != nil {
+				http.Error(w, "failed to load previous scan: "+err.Error(), http.StatusInternalServerError)
+				return
+			}
+		}
+		if against == nil {
+			http.Error(w, "no scan to compare against", http.StatusNotFound)
+			return
+		}
+		if run.Status != storage.ScanRunCompleted || against.Status != storage.ScanRunCompleted {
+			http.Error(w, "cannot diff a failed scan", http.StatusConflict)
+			return
+		}
+		json.NewEncoder(w).Encode(diffScanRuns(run, against))
+	})
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"testing"
+
+	"printmaster/agent/storage"
+)
+
+func TestDiffScanRuns(t *testing.T) {
+	t.Parallel()
+
+	before := &storage.ScanRun{
+		ID:     1,
+		Status: storage.ScanRunCompleted,
+		Ranges: []string{"10.0.0.0/24"},
+		Devices: []storage.ScanRunDevice{
+			{Serial: "KEEP", IP: "10.0.0.2", Firmware: "1.0", ScanID: 10},
+			{Serial: "MOVED", IP: "10.0.0.3", Firmware: "2.0", ScanID: 11},
+			{Serial: "GONE", IP: "10.0.0.4"},
+			{IP: "10.0.0.50"},
+		},
+	}
+	after := &storage.ScanRun{
+		ID:     2,
+		Status: storage.ScanRunCompleted,
+		Ranges: []string{"10.0.0.0/24"},
+		Devices: []storage.ScanRunDevice{
+			{Serial: "KEEP", IP: "10.0.0.2", Firmware: "1.0", ScanID: 20},
+			{Serial: "MOVED", IP: "10.0.0.30", Firmware: "2.1", ScanID: 21},
+			{Serial: "NEW", IP: "10.0.0.5"},
+			{IP: "10.0.0.50"},
+		},
+	}
+
+	diff := diffScanRuns(after, before)
+	if diff.ScopeChanged {
+		t.Error("expected same scope")
+	}
+	if diff.Unchanged != 2 {
+		t.Errorf("unchanged = %d, want 2", diff.Unchanged)
+	}
+	if len(diff.Added) != 1 || diff.Added[0].Serial != "NEW" {
+		t.Errorf("added = %+v", diff.Added)
+	}
+	if len(diff.Removed) != 1 || diff.Removed[0].Serial != "GONE" {
+		t.Errorf("removed = %+v", diff.Removed)
+	}
+	if len(diff.Changed) != 1 {
+		t.Fatalf("changed = %+v", diff.Changed)
+	}
+	change := diff.Changed[0]
+	if change.Key != "MOVED" || len(change.Changes) != 2 || change.BeforeScanID != 11 || change.AfterScanID != 21 {
+		t.Errorf("unexpected change: %+v", change)
+	}
+	if diff.Run.Devices != nil || diff.Against.Devices != nil {
+		t.Error("expected run summaries without device lists")
+	}
+
+	after.Ranges = []string{"10.0.1.0/24"}
+	if !diffScanRuns(after, before).ScopeChanged {
+		t.Error("expected scope change when ranges differ")
+	}
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"printmaster/agent/scanscope"
+	pmsettings "printmaster/common/settings"
+)
+
+// scanScopeRefusalLogInterval throttles refusal logs per target so a chatty
+// mDNS responder outside the scope does not flood the log.
+const scanScopeRefusalLogInterval = 10 * time.Minute
+
+func init() {
+	scanscope.SetRefusalObserver(logScanScopeRefusal)
+}
+
+func logScanScopeRefusal(target, source string) {
+	if appLogger == nil {
+		return
+	}
+	appLogger.WarnRateLimited("scan_scope_"+source+"_"+target, scanScopeRefusalLogInterval,
+		"Scan target outside authorized scan scope refused",
+		"target", target, "source", source, "scope", scanscope.Active().String())
+}
+
+// applyScanScopeSettings rebuilds the enforced scan scope from a discovery
+// settings map. A missing key leaves the current scope untouched.
+func applyScanScopeSettings(disc map[string]interface{}) {
+	raw, ok := disc["authorized_scan_scope"]
+	if !ok {
+		return
+	}
+	text, _ := raw.(string)
+	scope, invalid := scanscope.New(text)
+	if len(invalid) > 0 && appLogger != nil {
+		appLogger.Warn("Ignoring invalid authorized scan scope entries", "entries", strings.Join(invalid, ", "))
+	}
+	previous := scanscope.Active().String()
+	scanscope.SetActive(scope)
+	if appLogger == nil || previous == scope.String() {
+		return
+	}
+	if scope.Empty() {
+		appLogger.Info("Authorized scan scope cleared; scanning is unrestricted")
+	} else if len(scope.Prefixes) == 0 {
+		appLogger.Error("Authorized scan scope has no valid entries; all scanning refused")
+	} else {
+		appLogger.Info("Authorized scan scope updated", "scope", scope.String())
+	}
+}
+
+// filterScanTargets drops targets outside the authorized scan scope and logs
+// one summary line for the refused ones.
+func filterScanTargets(targets []string, source string) []string {
+	allowed, refused := scanscope.Active().Filter(targets)
+	if len(refused) > 0 && appLogger != nil {
+		sample := refused
+		if len(sample) > 5 {
+			sample = sample[:5]
+		}
+		appLogger.Warn("Scan targets outside authorized scan scope refused",
+			"source", source, "refused", len(refused), "allowed", len(allowed),
+			"sample", strings.Join(sample, ", "), "scope", scanscope.Active().String())
+	}
+	return allowed
+}
+
+// rejectOutOfScopeTarget writes 403 and returns true when ip is outside the
+// authorized scan scope.
+func rejectOutOfScopeTarget(w http.ResponseWriter, ip, source string) bool {
+	if err := scanscope.Check(ip, source); err != nil {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return true
+	}
+	return false
+}
+
+// validateDiscoveryScanScope checks proposed discovery settings against the
+// authorized scan scope: the scope itself must parse, and every saved range
+// target must fall inside it. Only the first few offending targets are listed.
+func validateDiscoveryScanScope(disc pmsettings.DiscoverySettings, rangeIPs []string) []pmsettings.ValidationError {
+	scope, invalid := scanscope.New(disc.AuthorizedScanScope)
+	if len(invalid) > 0 {
+		return []pmsettings.ValidationError{{Field: "discovery.authorized_scan_scope", Message: "invalid CIDR or IP: " + strings.Join(invalid, ", ")}}
+	}
+	_, refused := scope.Filter(rangeIPs)
+	if len(refused) == 0 {
+		return nil
+	}
+	sample := refused
+	if len(sample) > 5 {
+		sample = sample[:5]
+	}
+	return []pmsettings.ValidationError{{
+		Field: "discovery.ranges_text",
+		Message: fmt.Sprintf("%d address(es) are outside the authorized scan scope (%s): %s",
+			len(refused), scope.String(), strings.Join(sample, ", ")),
+	}}
+}
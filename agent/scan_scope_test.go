@@ -0,0 +1,50 @@
+package main
+
+import (
+	"testing"
+
+	"printmaster/agent/scanscope"
+	pmsettings "printmaster/common/settings"
+)
+
+func TestValidateDiscoveryScanScope(t *testing.T) {
+	disc := pmsettings.DefaultSettings().Discovery
+	if issues := validateDiscoveryScanScope(disc, []string{"203.0.113.5"}); len(issues) != 0 {
+		t.Fatalf("unrestricted scope: unexpected issues %+v", issues)
+	}
+
+	disc.AuthorizedScanScope = "10.20.0.0/16"
+	if issues := validateDiscoveryScanScope(disc, []string{"10.20.1.1", "10.20.1.2"}); len(issues) != 0 {
+		t.Fatalf("ranges inside scope: unexpected issues %+v", issues)
+	}
+	issues := validateDiscoveryScanScope(disc, []string{"10.20.1.1", "10.30.1.1"})
+	if len(issues) != 1 || issues[0].Field != "discovery.ranges_text" {
+		t.Fatalf("range outside scope: issues = %+v", issues)
+	}
+
+	disc.AuthorizedScanScope = "10.20.0.0/16\n10.30.0.0/40"
+	if issues := validateDiscoveryScanScope(disc, nil); len(issues) != 1 || issues[0].Field != "discovery.authorized_scan_scope" {
+		t.Fatalf("invalid scope: issues = %+v", issues)
+	}
+}
+
+func TestApplyScanScopeSettingsFiltersTargets(t *testing.T) {
+	t.Cleanup(func() { scanscope.SetActive(nil) })
+
+	applyScanScopeSettings(map[string]interface{}{"authorized_scan_scope": "192.168.10.0/24"})
+	got := filterScanTargets([]string{"192.168.10.4", "192.168.11.4"}, "test")
+	if len(got) != 1 || got[0] != "192.168.10.4" {
+		t.Fatalf("filterScanTargets = %v", got)
+	}
+
+	// Settings without the key leave the scope untouched
+	applyScanScopeSettings(map[string]interface{}{"auto_discover_enabled": true})
+	if scanscope.Active() == nil {
+		t.Fatal("scope cleared by unrelated settings")
+	}
+
+	applyScanScopeSettings(map[string]interface{}{"authorized_scan_scope": ""})
+	if scanscope.Active() != nil {
+		t.Fatal("empty setting should lift the restriction")
+	}
+}
@@ -0,0 +1,236 @@
+package scanner
+
+import (
+	"context"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/gosnmp/gosnmp"
+)
+
+// communitySweepRetryAfter is how long a device that rejected every candidate
+// is left alone before it is swept again.
+const communitySweepRetryAfter = 24 * time.Hour
+
+// Community sweep outcomes reported to the observer.
+const (
+	CommunitySweepAttemptFailed = "attempt_failed"
+	CommunitySweepMatched       = "matched"
+	CommunitySweepExhausted     = "exhausted"
+)
+
+// CommunitySweepConfig enables trying a list of SNMPv1/v2c communities against
+// devices that do not answer the configured one. Intended for authorized
+// onboarding sweeps only.
+type CommunitySweepConfig struct {
+	Enabled    bool
+	Candidates []string
+	// PerSecond caps attempts per second across all devices
+	PerSecond int
+}
+
+// CommunitySweepEvent describes one step of a sweep. Index is the 1-based
+// position of the candidate in the configured list, so audit records can
+// identify the credential without containing it. Community is only set on
+// CommunitySweepMatched so the caller can persist it.
+type CommunitySweepEvent struct {
+	IP        string
+	Outcome   string
+	Index     int
+	Attempts  int
+	Community string
+}
+
+type communitySweeper struct {
+	mu         sync.Mutex
+	cfg        CommunitySweepConfig
+	remembered map[string]string
+	exhausted  map[string]time.Time
+	nextSlot   time.Time
+	observer   func(CommunitySweepEvent)
+}
+
+var sweeper = &communitySweeper{
+	remembered: make(map[string]string),
+	exhausted:  make(map[string]time.Time),
+}
+
+// SetCommunitySweep replaces the sweep configuration. When the candidate list
+// changes, devices that exhausted the previous list become eligible again.
+func SetCommunitySweep(cfg CommunitySweepConfig) {
+	sweeper.mu.Lock()
+	defer sweeper.mu.Unlock()
+	if cfg.PerSecond <= 0 {
+		cfg.PerSecond = 1
+	}
+	cfg.Candidates = append([]string(nil), cfg.Candidates...)
+	if !slices.Equal(sweeper.cfg.Candidates, cfg.Candidates) {
+		sweeper.exhausted = make(map[string]time.Time)
+	}
+	sweeper.cfg = cfg
+}
+
+// SetCommunitySweepObserver registers fn to receive every sweep event.
+func SetCommunitySweepObserver(fn func(CommunitySweepEvent)) {
+	sweeper.mu.Lock()
+	sweeper.observer = fn
+	sweeper.mu.Unlock()
+}
+
+// LoadRememberedCommunities seeds the per-device communities found by earlier
+// sweeps, keyed by IP.
+func LoadRememberedCommunities(communities map[string]string) {
+	sweeper.mu.Lock()
+	defer sweeper.mu.Unlock()
+	sweeper.remembered = make(map[string]string, len(communities))
+	for ip, community := range communities {
+		if ip != "" && community != "" {
+			sweeper.remembered[ip] = community
+		}
+	}
+}
+
+// RememberedCommunity returns the community a sweep found for ip.
+func RememberedCommunity(ip string) (string, bool) {
+	sweeper.mu.Lock()
+	defer sweeper.mu.Unlock()
+	community, ok := sweeper.remembered[ip]
+	return community, ok
+}
+
+// configForTarget returns the config to use for target: the credential
+// profile remembered for it, else cfg with the remembered community, if any.
+// Configs that already come from a profile are returned as-is. cfg itself is
+// never modified.
+func configForTarget(cfg *SNMPConfig, target string) *SNMPConfig {
+	if cfg == nil || cfg.ProfileID != 0 {
+		return cfg
+	}
+	if p, ok := RememberedProfile(target); ok {
+		return profileConfig(p)
+	}
+	if cfg.Version == gosnmp.Version3 {
+		return cfg
+	}
+	community, ok := RememberedCommunity(target)
+	if !ok || community == cfg.Community {
+		return cfg
+	}
+	copied := *cfg
+	copied.Community = community
+	return &copied
+}
+
+// waitSlot blocks until the global sweep rate allows another attempt.
+func (s *communitySweeper) waitSlot(ctx context.Context, perSecond int) error {
+	s.mu.Lock()
+	now := time.Now()
+	slot := s.nextSlot
+	if slot.Before(now) {
+		slot = now
+	}
+	s.nextSlot = slot.Add(time.Second / time.Duration(perSecond))
+	s.mu.Unlock()
+
+	wait := time.Until(slot)
+	if wait <= 0 {
+		return ctx.Err()
+	}
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+func (s *communitySweeper) emit(ev CommunitySweepEvent) {
+	s.mu.Lock()
+	observer := s.observer
+	s.mu.Unlock()
+	if observer != nil {
+		observer(ev)
+	}
+}
+
+// sweepCommunities tries each candidate community against ip until one answers
+// probeOIDs. On success the working client and response are returned and the
+// community is remembered for the device; the caller owns the client.
+func sweepCommunities(ctx context.Context, cfg *SNMPConfig, ip string, timeoutSeconds int, clientFactory func(*SNMPConfig, string, int) (SNMPClient, error), probeOIDs []string) (SNMPClient, *gosnmp.SnmpPacket) {
+	if cfg == nil || cfg.Version == gosnmp.Version3 {
+		return nil, nil
+	}
+	// Devices reached through a credential profile are not swept
+	if _, ok := RememberedProfile(ip); ok {
+		return nil, nil
+	}
+	sweeper.mu.Lock()
+	sweepCfg := sweeper.cfg
+	failedAt, exhausted := sweeper.exhausted[ip]
+	skip := sweeper.remembered[ip]
+	sweeper.mu.Unlock()
+	if !sweepCfg.Enabled || len(sweepCfg.Candidates) == 0 {
+		return nil, nil
+	}
+	if exhausted && time.Since(failedAt) < communitySweepRetryAfter {
+		return nil, nil
+	}
+	if skip == "" {
+		skip = cfg.Community
+	}
+
+	attempts := 0
+	for i, community := range sweepCfg.Candidates {
+		if community == skip {
+			continue
+		}
+		if err := sweeper.waitSlot(ctx, sweepCfg.PerSecond); err != nil {
+			return nil, nil
+		}
+		attempts++
+		candidate := *cfg
+		candidate.Community = community
+		client, err := clientFactory(&candidate, ip, timeoutSeconds)
+		if err != nil {
+			sweeper.emit(CommunitySweepEvent{IP: ip, Outcome: CommunitySweepAttemptFailed, Index: i + 1, Attempts: attempts})
+			continue
+		}
+		res, err := client.Get(probeOIDs)
+		if err == nil && hasSNMPValues(res) {
+			sweeper.mu.Lock()
+			sweeper.remembered[ip] = community
+			delete(sweeper.exhausted, ip)
+			sweeper.mu.Unlock()
+			sweeper.emit(CommunitySweepEvent{IP: ip, Outcome: CommunitySweepMatched, Index: i + 1, Attempts: attempts, Community: community})
+			return client, res
+		}
+		client.Close()
+		sweeper.emit(CommunitySweepEvent{IP: ip, Outcome: CommunitySweepAttemptFailed, Index: i + 1, Attempts: attempts})
+	}
+
+	sweeper.mu.Lock()
+	sweeper.exhausted[ip] = time.Now()
+	sweeper.mu.Unlock()
+	if attempts > 0 {
+		sweeper.emit(CommunitySweepEvent{IP: ip, Outcome: CommunitySweepExhausted, Attempts: attempts})
+	}
+	return nil, nil
+}
+
+// hasSNMPValues reports whether the response carries at least one real value.
+func hasSNMPValues(res *gosnmp.SnmpPacket) bool {
+	if res == nil {
+		return false
+	}
+	for _, pdu := range res.Variables {
+		switch pdu.Type {
+		case gosnmp.NoSuchObject, gosnmp.NoSuchInstance, gosnmp.EndOfMibView, gosnmp.Null:
+			continue
+		}
+		return true
+	}
+	return false
+}
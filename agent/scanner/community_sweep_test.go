@@ -0,0 +1,72 @@
+package scanner
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"printmaster/common/snmp/oids"
+
+	"github.com/gosnmp/gosnmp"
+)
+
+func TestQueryDevice_CommunitySweep(t *testing.T) {
+	SetCommunitySweep(CommunitySweepConfig{Enabled: true, Candidates: []string{"public", "wrong", "secret"}, PerSecond: 10})
+	var events []CommunitySweepEvent
+	SetCommunitySweepObserver(func(ev CommunitySweepEvent) { events = append(events, ev) })
+	defer func() {
+		SetCommunitySweep(CommunitySweepConfig{})
+		SetCommunitySweepObserver(nil)
+		LoadRememberedCommunities(nil)
+	}()
+
+	answer := &gosnmp.SnmpPacket{Variables: []gosnmp.SnmpPDU{
+		{Name: "." + oids.SysObjectID, Type: gosnmp.ObjectIdentifier, Value: ".1.3.6.1.4.1.11.2.3.9.1"},
+		{Name: "." + oids.SysDescr, Type: gosnmp.OctetString, Value: []byte("HP LaserJet")},
+	}}
+	var tried []string
+	factory := func(cfg *SNMPConfig, target string, timeoutSeconds int) (SNMPClient, error) {
+		tried = append(tried, cfg.Community)
+		if cfg.Community == "secret" {
+			return &mockSNMPClient{getResult: answer}, nil
+		}
+		return &mockSNMPClient{getErr: errors.New("request timeout")}, nil
+	}
+
+	if _, err := queryDeviceWithCapabilitiesAndClient(context.Background(), "10.9.0.1", QueryMinimal, "", 1, nil, factory); err != nil {
+		t.Fatalf("query with swept community failed: %v", err)
+	}
+	// The configured community ("public") is not retried during the sweep
+	if len(tried) != 3 || tried[1] != "wrong" || tried[2] != "secret" {
+		t.Fatalf("unexpected attempts: %q", tried)
+	}
+	if community, ok := RememberedCommunity("10.9.0.1"); !ok || community != "secret" {
+		t.Fatalf("working community not remembered: %q %v", community, ok)
+	}
+	last := events[len(events)-1]
+	if last.Outcome != CommunitySweepMatched || last.Index != 3 || last.Attempts != 2 {
+		t.Fatalf("unexpected match event: %+v", last)
+	}
+	if cfg := configForTarget(&SNMPConfig{Community: "public", Version: gosnmp.Version2c}, "10.9.0.1"); cfg.Community != "secret" {
+		t.Fatalf("remembered community not applied, got %q", cfg.Community)
+	}
+
+	// A device that rejects every candidate is not swept again right away
+	tried = nil
+	events = nil
+	failing := func(cfg *SNMPConfig, target string, timeoutSeconds int) (SNMPClient, error) {
+		tried = append(tried, cfg.Community)
+		return &mockSNMPClient{getErr: errors.New("request timeout")}, nil
+	}
+	if _, err := queryDeviceWithCapabilitiesAndClient(context.Background(), "10.9.0.2", QueryMinimal, "", 1, nil, failing); err == nil {
+		t.Fatal("expected failure when no community works")
+	}
+	if len(events) == 0 || events[len(events)-1].Outcome != CommunitySweepExhausted {
+		t.Fatalf("expected exhausted event, got %+v", events)
+	}
+	tried = nil
+	_, _ = queryDeviceWithCapabilitiesAndClient(context.Background(), "10.9.0.2", QueryMinimal, "", 1, nil, failing)
+	if len(tried) != 1 {
+		t.Fatalf("exhausted device should not be swept again, attempts: %q", tried)
+	}
+}
@@ -0,0 +1,177 @@
+package scanner
+
+import (
+	"context"
+	"net"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/gosnmp/gosnmp"
+)
+
+// credentialProfileRetryAfter is how long a device that rejected every
+// matching profile is left alone before the profiles are tried again.
+const credentialProfileRetryAfter = time.Hour
+
+// CredentialProfile is a named set of SNMP credentials tried, in priority
+// order, against devices that do not answer the default configuration.
+type CredentialProfile struct {
+	ID       int64
+	Name     string
+	Priority int // lower values are tried first
+	// Subnets limits the profile to these networks; empty matches every device
+	Subnets []*net.IPNet
+	Config  SNMPConfig
+}
+
+// Matches reports whether the profile applies to ip.
+func (p CredentialProfile) Matches(ip string) bool {
+	if len(p.Subnets) == 0 {
+		return true
+	}
+	addr := net.ParseIP(ip)
+	if addr == nil {
+		return false
+	}
+	for _, n := range p.Subnets {
+		if n.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+type credentialProfileSet struct {
+	mu         sync.Mutex
+	profiles   []CredentialProfile
+	remembered map[string]int64
+	exhausted  map[string]time.Time
+	observer   func(ip string, profileID int64)
+}
+
+var credentialProfiles = &credentialProfileSet{
+	remembered: make(map[string]int64),
+	exhausted:  make(map[string]time.Time),
+}
+
+// SetCredentialProfiles replaces the credential profiles. Devices that
+// rejected the previous profiles become eligible again.
+func SetCredentialProfiles(profiles []CredentialProfile) {
+	sorted := append([]CredentialProfile(nil), profiles...)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].Priority < sorted[j].Priority })
+	credentialProfiles.mu.Lock()
+	defer credentialProfiles.mu.Unlock()
+	credentialProfiles.profiles = sorted
+	credentialProfiles.exhausted = make(map[string]time.Time)
+}
+
+// SetCredentialProfileObserver registers fn to be told when a different
+// profile starts working for a device.
+func SetCredentialProfileObserver(fn func(ip string, profileID int64)) {
+	credentialProfiles.mu.Lock()
+	credentialProfiles.observer = fn
+	credentialProfiles.mu.Unlock()
+}
+
+// LoadRememberedProfiles seeds the profile that last worked for each device,
+// keyed by IP.
+func LoadRememberedProfiles(remembered map[string]int64) {
+	credentialProfiles.mu.Lock()
+	defer credentialProfiles.mu.Unlock()
+	credentialProfiles.remembered = make(map[string]int64, len(remembered))
+	for ip, id := range remembered {
+		if ip != "" && id != 0 {
+			credentialProfiles.remembered[ip] = id
+		}
+	}
+}
+
+// RememberedProfile returns the profile that last worked for ip, if it still
+// exists and applies to ip.
+func RememberedProfile(ip string) (CredentialProfile, bool) {
+	credentialProfiles.mu.Lock()
+	defer credentialProfiles.mu.Unlock()
+	id, ok := credentialProfiles.remembered[ip]
+	if !ok {
+		return CredentialProfile{}, false
+	}
+	for _, p := range credentialProfiles.profiles {
+		if p.ID == id && p.Matches(ip) {
+			return p, true
+		}
+	}
+	return CredentialProfile{}, false
+}
+
+// profileConfig returns the client config for p.
+func profileConfig(p CredentialProfile) *SNMPConfig {
+	cfg := p.Config
+	cfg.ProfileID = p.ID
+	return &cfg
+}
+
+func (s *credentialProfileSet) emit(ip string, profileID int64) {
+	s.mu.Lock()
+	observer := s.observer
+	s.mu.Unlock()
+	if observer != nil {
+		observer(ip, profileID)
+	}
+}
+
+// tryCredentialProfiles tries the profiles matching ip in priority order
+// until one answers probeOIDs, skipping the one cfg already resolved to. On
+// success the working client and response are returned and the profile is
+// remembered for the device; the caller owns the client.
+func tryCredentialProfiles(ctx context.Context, cfg *SNMPConfig, ip string, timeoutSeconds int, clientFactory func(*SNMPConfig, string, int) (SNMPClient, error), probeOIDs []string) (SNMPClient, *gosnmp.SnmpPacket) {
+	var skip int64
+	if resolved := configForTarget(cfg, ip); resolved != nil {
+		skip = resolved.ProfileID
+	}
+
+	credentialProfiles.mu.Lock()
+	var candidates []CredentialProfile
+	for _, p := range credentialProfiles.profiles {
+		if p.ID != skip && p.Matches(ip) {
+			candidates = append(candidates, p)
+		}
+	}
+	failedAt, exhausted := credentialProfiles.exhausted[ip]
+	remembered := credentialProfiles.remembered[ip]
+	credentialProfiles.mu.Unlock()
+	if len(candidates) == 0 || (exhausted && time.Since(failedAt) < credentialProfileRetryAfter) {
+		return nil, nil
+	}
+
+	for _, p := range candidates {
+		if ctx.Err() != nil {
+			return nil, nil
+		}
+		client, err := clientFactory(profileConfig(p), ip, timeoutSeconds)
+		if err != nil {
+			continue
+		}
+		res, err := client.Get(probeOIDs)
+		if err == nil && hasSNMPValues(res) {
+			credentialProfiles.mu.Lock()
+			credentialProfiles.remembered[ip] = p.ID
+			delete(credentialProfiles.exhausted, ip)
+			credentialProfiles.mu.Unlock()
+			if p.ID != remembered {
+				credentialProfiles.emit(ip, p.ID)
+			}
+			return client, res
+		}
+		client.Close()
+	}
+
+	// A device with a remembered profile is probably just offline; keep the
+	// profile and try again next time.
+	if remembered == 0 {
+		credentialProfiles.mu.Lock()
+		credentialProfiles.exhausted[ip] = time.Now()
+		credentialProfiles.mu.Unlock()
+	}
+	return nil, nil
+}
@@ -0,0 +1,104 @@
+package scanner
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+
+	"printmaster/common/snmp/oids"
+
+	"github.com/gosnmp/gosnmp"
+)
+
+func TestQueryDevice_CredentialProfiles(t *testing.T) {
+	_, office, _ := net.ParseCIDR("10.8.0.0/24")
+	_, lab, _ := net.ParseCIDR("10.99.0.0/24")
+	SetCredentialProfiles([]CredentialProfile{
+		{ID: 3, Name: "office-v3", Priority: 20, Subnets: []*net.IPNet{office},
+			Config: SNMPConfig{Version: gosnmp.Version3, SecurityLevel: gosnmp.AuthPriv, Username: "printers"}},
+		{ID: 1, Name: "lab", Priority: 5, Subnets: []*net.IPNet{lab}, Config: SNMPConfig{Version: gosnmp.Version3, Username: "lab"}},
+		{ID: 2, Name: "legacy-v3", Priority: 10, Config: SNMPConfig{Version: gosnmp.Version3, SecurityLevel: gosnmp.AuthNoPriv, Username: "legacy"}},
+	})
+	var matched []int64
+	SetCredentialProfileObserver(func(ip string, id int64) { matched = append(matched, id) })
+	defer func() {
+		SetCredentialProfiles(nil)
+		SetCredentialProfileObserver(nil)
+		LoadRememberedProfiles(nil)
+	}()
+
+	answer := &gosnmp.SnmpPacket{Variables: []gosnmp.SnmpPDU{
+		{Name: "." + oids.SysObjectID, Type: gosnmp.ObjectIdentifier, Value: ".1.3.6.1.4.1.11.2.3.9.1"},
+		{Name: "." + oids.SysDescr, Type: gosnmp.OctetString, Value: []byte("HP LaserJet")},
+	}}
+	var tried []string
+	factory := func(cfg *SNMPConfig, target string, timeoutSeconds int) (SNMPClient, error) {
+		tried = append(tried, cfg.Username)
+		if cfg.Username == "printers" {
+			return &mockSNMPClient{getResult: answer}, nil
+		}
+		return &mockSNMPClient{getErr: errors.New("wrong digest")}, nil
+	}
+
+	if _, err := queryDeviceWithCapabilitiesAndClient(context.Background(), "10.8.0.7", QueryMinimal, "", 1, nil, factory); err != nil {
+		t.Fatalf("query with credential profile failed: %v", err)
+	}
+	// Default config first, then matching profiles by priority; "lab" is out of subnet
+	if len(tried) != 3 || tried[1] != "legacy" || tried[2] != "printers" {
+		t.Fatalf("unexpected attempts: %q", tried)
+	}
+	if p, ok := RememberedProfile("10.8.0.7"); !ok || p.ID != 3 {
+		t.Fatalf("working profile not remembered: %+v %v", p, ok)
+	}
+	if len(matched) != 1 || matched[0] != 3 {
+		t.Fatalf("observer not told about the match: %v", matched)
+	}
+	cfg := configForTarget(&SNMPConfig{Community: "public", Version: gosnmp.Version2c}, "10.8.0.7")
+	if cfg.ProfileID != 3 || cfg.Version != gosnmp.Version3 || cfg.SecurityLevel != gosnmp.AuthPriv {
+		t.Fatalf("remembered profile not applied: %+v", cfg)
+	}
+	if configForTarget(cfg, "10.8.0.7") != cfg {
+		t.Fatal("profile configs must be used unchanged")
+	}
+
+	// A device that rejects every profile is left alone for a while
+	tried = nil
+	failing := func(cfg *SNMPConfig, target string, timeoutSeconds int) (SNMPClient, error) {
+		tried = append(tried, cfg.Username)
+		return &mockSNMPClient{getErr: errors.New("request timeout")}, nil
+	}
+	_, _ = queryDeviceWithCapabilitiesAndClient(context.Background(), "10.99.0.4", QueryMinimal, "", 1, nil, failing)
+	if len(tried) != 3 || tried[1] != "lab" || tried[2] != "legacy" {
+		t.Fatalf("unexpected attempts: %q", tried)
+	}
+	tried = nil
+	_, _ = queryDeviceWithCapabilitiesAndClient(context.Background(), "10.99.0.4", QueryMinimal, "", 1, nil, failing)
+	if len(tried) != 1 {
+		t.Fatalf("profiles retried too soon: %q", tried)
+	}
+
+	// A remembered profile survives the device going offline
+	_, _ = queryDeviceWithCapabilitiesAndClient(context.Background(), "10.8.0.7", QueryMinimal, "", 1, nil, failing)
+	if _, ok := RememberedProfile("10.8.0.7"); !ok {
+		t.Fatal("remembered profile dropped after a failed query")
+	}
+}
+
+func TestParseSNMPSecuritySettings(t *testing.T) {
+	if v, err := ParseSNMPVersion("v3"); err != nil || v != gosnmp.Version3 {
+		t.Fatalf("version: %v %v", v, err)
+	}
+	if l, err := ParseSNMPSecurityLevel("authPriv"); err != nil || l != gosnmp.AuthPriv {
+		t.Fatalf("level: %v %v", l, err)
+	}
+	if a, err := ParseSNMPAuthProtocol("sha256"); err != nil || a != gosnmp.SHA256 {
+		t.Fatalf("auth: %v %v", a, err)
+	}
+	if p, err := ParseSNMPPrivProtocol("AES"); err != nil || p != gosnmp.AES {
+		t.Fatalf("priv: %v %v", p, err)
+	}
+	if _, err := ParseSNMPPrivProtocol("3DES"); err == nil {
+		t.Fatal("expected an error for an unknown privacy protocol")
+	}
+}
@@ -3,6 +3,7 @@ package scanner
 import (
 	"context"
 	"fmt"
+	"time"
 )
 
 // SavedDeviceChecker is an interface for checking if a device IP is already known.
@@ -22,6 +23,43 @@ type DetectorConfig struct {
 
 	// SkipSavedDevices when true, bypasses SNMP for known devices
 	SkipSavedDevices bool
+
+	// SNMPDisabled skips SNMP entirely; printers are identified over IPP
+	SNMPDisabled bool
+
+	// IPPFunc overrides the IPP query (useful for tests). If nil, QueryIPP
+	// is used.
+	IPPFunc func(ctx context.Context, ip string, timeout time.Duration) (*IPPResult, error)
+}
+
+// queryIPP asks a host with the IPP port open for its printer attributes. A
+// result without a make and model is not treated as a printer.
+func (cfg DetectorConfig) queryIPP(ctx context.Context, ip string, openPorts []int) *IPPResult {
+	if !hasPort(openPorts, DefaultIPPPort) {
+		return nil
+	}
+	query := cfg.IPPFunc
+	if query == nil {
+		query = QueryIPP
+	}
+	timeout := time.Duration(cfg.SNMPTimeout) * time.Second
+	if timeout <= 0 || timeout > 10*time.Second {
+		timeout = 10 * time.Second
+	}
+	res, err := query(ctx, ip, timeout)
+	if err != nil || res == nil || res.MakeAndModel == "" {
+		return nil
+	}
+	return res
+}
+
+func hasPort(ports []int, port int) bool {
+	for _, p := range ports {
+		if p == port {
+			return true
+		}
+	}
+	return false
 }
 
 // DetectFunc creates a detection function that uses QueryDevice for compact printer detection.
@@ -31,6 +69,8 @@ type DetectorConfig struct {
 //  1. If SkipSavedDevices=true and device is known, return cached data immediately
 //  2. Otherwise, use QueryDevice with QueryMinimal profile to get serial number
 //  3. If serial found, mark as printer
+//  4. If SNMP is disabled, blocked or finds no serial and port 631 is open,
+//     query the printer attributes over IPP (the info is then an *IPPResult)
 func DetectFunc(cfg DetectorConfig) func(ctx context.Context, job ScanJob, openPorts []int) (interface{}, bool, error) {
 	if cfg.SNMPTimeout == 0 {
 		cfg.SNMPTimeout = 5 // default 5 second timeout for detection
@@ -46,21 +86,28 @@ func DetectFunc(cfg DetectorConfig) func(ctx context.Context, job ScanJob, openP
 			}
 		}
 
-		// 2. No open ports = can't be a network printer
-		if len(openPorts) == 0 {
+		// 2. No open ports = can't be a network printer, unless the device
+		// is reached through explicitly forwarded ports
+		if _, overridden := PortOverrideFor(job.IP); len(openPorts) == 0 && !overridden {
 			return nil, false, nil
 		}
 
-		// 3. Use QueryDevice with QueryMinimal to check for printer
-		// This queries only serial number OIDs (fastest check)
-		result, err := QueryDevice(ctx, job.IP, QueryMinimal, "", cfg.SNMPTimeout)
-		if err != nil {
-			// SNMP failed - not a printer or SNMP disabled
+		if cfg.SNMPDisabled {
+			if ipp := cfg.queryIPP(ctx, job.IP, openPorts); ipp != nil {
+				return ipp, true, nil
+			}
 			return nil, false, nil
 		}
 
-		// 4. Check if we got any SNMP data
-		if result == nil || len(result.PDUs) == 0 {
+		// 3. Use QueryDevice with QueryMinimal to check for printer
+		// This queries only serial number OIDs (fastest check)
+		result, err := QueryDevice(ctx, job.IP, QueryMinimal, "", cfg.SNMPTimeout)
+		if err != nil || result == nil || len(result.PDUs) == 0 {
+			// SNMP failed or returned nothing - SNMP may be off on a modern
+			// printer, so ask over IPP before giving up
+			if ipp := cfg.queryIPP(ctx, job.IP, openPorts); ipp != nil {
+				return ipp, true, nil
+			}
 			return nil, false, nil
 		}
 
@@ -94,7 +141,10 @@ func DetectFunc(cfg DetectorConfig) func(ctx context.Context, job ScanJob, openP
 			return result, true, nil
 		}
 
-		// No serial found = probably not a printer
+		// No serial found = probably not a printer, unless it answers IPP
+		if ipp := cfg.queryIPP(ctx, job.IP, openPorts); ipp != nil {
+			return ipp, true, nil
+		}
 		return result, false, nil
 	}
 }
@@ -112,6 +162,11 @@ func DeepScanFunc(cfg DetectorConfig) func(ctx context.Context, dr DetectionResu
 	}
 
 	return func(ctx context.Context, dr DetectionResult) (interface{}, error) {
+		// Printers found over IPP have nothing more to read via SNMP
+		if ipp, ok := dr.Info.(*IPPResult); ok {
+			return ipp, nil
+		}
+
 		// 1. Extract vendor hint from detection result
 		vendorHint := ""
 		if dr.Info != nil {
@@ -0,0 +1,109 @@
+package discovery
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// FieldType is the value type of a method option.
+type FieldType string
+
+const (
+	FieldString   FieldType = "string"
+	FieldInt      FieldType = "int"
+	FieldBool     FieldType = "bool"
+	FieldDuration FieldType = "duration" // Go duration syntax, e.g. "3s"
+)
+
+// ConfigField describes one option of a method.
+type ConfigField struct {
+	Key     string    `json:"key"`
+	Label   string    `json:"label"`
+	Type    FieldType `json:"type"`
+	Default string    `json:"default,omitempty"`
+	Help    string    `json:"help,omitempty"`
+}
+
+// Settings are the stored settings of one method.
+type Settings struct {
+	Enabled bool              `json:"enabled"`
+	Options map[string]string `json:"options,omitempty"`
+}
+
+// Config is a method's validated options, with schema defaults applied.
+type Config struct {
+	values map[string]string
+}
+
+// NewConfig validates options against the method's schema. Unknown keys and
+// values that do not parse as the field's type are rejected.
+func NewConfig(m Method, options map[string]string) (Config, error) {
+	schema := make(map[string]ConfigField)
+	values := make(map[string]string)
+	for _, f := range m.ConfigSchema() {
+		schema[f.Key] = f
+		values[f.Key] = f.Default
+	}
+	keys := make([]string, 0, len(options))
+	for k := range options {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		f, ok := schema[key]
+		if !ok {
+			return Config{}, fmt.Errorf("%s: unknown option %q", m.Name(), key)
+		}
+		v := strings.TrimSpace(options[key])
+		if v == "" {
+			continue
+		}
+		if err := checkFieldValue(f, v); err != nil {
+			return Config{}, fmt.Errorf("%s: option %q: %w", m.Name(), key, err)
+		}
+		values[key] = v
+	}
+	return Config{values: values}, nil
+}
+
+func checkFieldValue(f ConfigField, v string) error {
+	var err error
+	switch f.Type {
+	case FieldInt:
+		_, err = strconv.Atoi(v)
+	case FieldBool:
+		_, err = strconv.ParseBool(v)
+	case FieldDuration:
+		var d time.Duration
+		if d, err = time.ParseDuration(v); err == nil && d < 0 {
+			err = fmt.Errorf("must not be negative")
+		}
+	}
+	return err
+}
+
+// String returns an option's value, or "" if unset.
+func (c Config) String(key string) string {
+	return c.values[key]
+}
+
+// Int returns an option as an int, or 0 if unset.
+func (c Config) Int(key string) int {
+	n, _ := strconv.Atoi(c.values[key])
+	return n
+}
+
+// Bool returns an option as a bool, or false if unset.
+func (c Config) Bool(key string) bool {
+	b, _ := strconv.ParseBool(c.values[key])
+	return b
+}
+
+// Duration returns an option as a duration, or 0 if unset.
+func (c Config) Duration(key string) time.Duration {
+	d, _ := time.ParseDuration(c.values[key])
+	return d
+}
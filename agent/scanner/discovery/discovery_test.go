@@ -0,0 +1,170 @@
+package discovery
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+)
+
+type fakeActive struct {
+	name string
+	ips  []string
+}
+
+func (f *fakeActive) Name() string        { return f.name }
+func (f *fakeActive) Description() string { return "fake active" }
+func (f *fakeActive) ConfigSchema() []ConfigField {
+	return []ConfigField{
+		{Key: "port", Type: FieldInt, Default: "47808"},
+		{Key: "timeout", Type: FieldDuration, Default: "2s"},
+	}
+}
+func (f *fakeActive) Discover(ctx context.Context, scope Scope, cfg Config, emit func(Result)) error {
+	for _, ip := range f.ips {
+		emit(Result{IP: ip})
+	}
+	return nil
+}
+
+type fakePassive struct {
+	name    string
+	mu      sync.Mutex
+	starts  int
+	lastCfg Config
+}
+
+func (f *fakePassive) Name() string        { return f.name }
+func (f *fakePassive) Description() string { return "fake passive" }
+func (f *fakePassive) ConfigSchema() []ConfigField {
+	return []ConfigField{{Key: "group", Type: FieldString}}
+}
+func (f *fakePassive) Listen(ctx context.Context, cfg Config, emit func(Result)) error {
+	f.mu.Lock()
+	f.starts++
+	f.lastCfg = cfg
+	f.mu.Unlock()
+	emit(Result{IP: "10.0.0.9"})
+	emit(Result{IP: "10.0.0.9"})
+	<-ctx.Done()
+	return nil
+}
+
+func registerForTest(t *testing.T, m Method) {
+	t.Helper()
+	Register(m)
+	t.Cleanup(func() { unregister(m.Name()) })
+}
+
+func TestNewConfig(t *testing.T) {
+	m := &fakeActive{name: "fake"}
+	cfg, err := NewConfig(m, map[string]string{"timeout": "5s"})
+	if err != nil {
+		t.Fatalf("NewConfig: %v", err)
+	}
+	if cfg.Int("port") != 47808 || cfg.Duration("timeout") != 5*time.Second {
+		t.Fatalf("unexpected config: port=%d timeout=%s", cfg.Int("port"), cfg.Duration("timeout"))
+	}
+	if _, err := NewConfig(m, map[string]string{"bogus": "1"}); err == nil {
+		t.Fatal("expected unknown option to be rejected")
+	}
+	if _, err := NewConfig(m, map[string]string{"port": "abc"}); err == nil {
+		t.Fatal("expected non-integer port to be rejected")
+	}
+}
+
+func TestRegisterRejectsDuplicates(t *testing.T) {
+	registerForTest(t, &fakeActive{name: "dup"})
+	defer func() {
+		if recover() == nil {
+			t.Fatal("expected panic on duplicate registration")
+		}
+	}()
+	Register(&fakeActive{name: "dup"})
+}
+
+func TestRunActive(t *testing.T) {
+	registerForTest(t, &fakeActive{name: "one", ips: []string{"10.0.0.1", "10.0.0.2", "not-an-ip", "::1"}})
+	registerForTest(t, &fakeActive{name: "two", ips: []string{"10.0.0.2", "10.0.0.3"}})
+	registerForTest(t, &fakeActive{name: "off", ips: []string{"10.0.0.4"}})
+	registerForTest(t, &fakeActive{name: "broken"})
+
+	var mu sync.Mutex
+	var got []string
+	settings := map[string]Settings{
+		"one":    {Enabled: true},
+		"two":    {Enabled: true},
+		"broken": {Enabled: true, Options: map[string]string{"port": "x"}},
+	}
+	errs := RunActive(context.Background(), settings, Scope{}, func(r Result) {
+		if r.Method != "one" && r.Method != "two" {
+			t.Errorf("unexpected method %q", r.Method)
+		}
+		mu.Lock()
+		got = append(got, r.IP)
+		mu.Unlock()
+	})
+	sort.Strings(got)
+	want := []string{"10.0.0.1", "10.0.0.2", "10.0.0.3"}
+	if len(got) != len(want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("got %v, want %v", got, want)
+		}
+	}
+	if len(errs) != 1 || errs["broken"] == nil {
+		t.Fatalf("expected only broken to fail, got %v", errs)
+	}
+}
+
+func TestListenersApply(t *testing.T) {
+	m := &fakePassive{name: "listen"}
+	registerForTest(t, m)
+
+	results := make(chan Result, 4)
+	l := NewListeners(func(r Result) { results <- r }, nil)
+	defer l.StopAll()
+
+	l.Apply(map[string]Settings{"listen": {Enabled: true}})
+	select {
+	case r := <-results:
+		if r.IP != "10.0.0.9" || r.Method != "listen" {
+			t.Fatalf("unexpected result %+v", r)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("listener did not emit")
+	}
+	if names := l.Running(); len(names) != 1 || names[0] != "listen" {
+		t.Fatalf("Running = %v", names)
+	}
+
+	// Same settings keep the listener; changed options restart it
+	l.Apply(map[string]Settings{"listen": {Enabled: true}})
+	l.Apply(map[string]Settings{"listen": {Enabled: true, Options: map[string]string{"group": "b"}}})
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		m.mu.Lock()
+		starts, group := m.starts, m.lastCfg.String("group")
+		m.mu.Unlock()
+		if starts == 2 && group == "b" {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("expected one restart with new options, starts=%d group=%q", starts, group)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	select {
+	case <-results:
+	case <-time.After(2 * time.Second):
+		t.Fatal("restarted listener did not emit")
+	}
+
+	l.Apply(nil)
+	if names := l.Running(); len(names) != 0 {
+		t.Fatalf("expected listener stopped, Running = %v", names)
+	}
+}
@@ -0,0 +1,118 @@
+// Package discovery defines pluggable discovery methods: probes that find
+// candidate printer IPs in ways the built-in scanner does not (BACnet,
+// proprietary vendor broadcasts, ...). A method only reports IPs; every
+// candidate is identified and stored by the standard live-discovery path
+// (SNMP detection, deep scan, SSE events), so adding a method never touches
+// the scan pipeline.
+//
+// Methods live in their own files and register themselves from init():
+//
+//	func init() { discovery.Register(&bacnetMethod{}) }
+//
+// A method implements ActiveMethod, PassiveMethod or both.
+package discovery
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Method describes a discovery method.
+type Method interface {
+	// Name is the stable identifier used in settings, logs and a device's
+	// discovery_methods (lowercase, e.g. "bacnet").
+	Name() string
+
+	// Description is a one-line summary shown in the UI.
+	Description() string
+
+	// ConfigSchema lists the options the method accepts. Whether the method
+	// is enabled is handled by the agent and is not part of the schema.
+	ConfigSchema() []ConfigField
+}
+
+// ActiveMethod probes the network while a discovery scan runs.
+type ActiveMethod interface {
+	Method
+
+	// Discover reports candidates through emit and returns when done or
+	// when ctx is cancelled. It runs once per scan, alongside the pipeline.
+	Discover(ctx context.Context, scope Scope, cfg Config, emit func(Result)) error
+}
+
+// PassiveMethod listens in the background while it is enabled.
+type PassiveMethod interface {
+	Method
+
+	// Listen reports candidates through emit until ctx is cancelled.
+	Listen(ctx context.Context, cfg Config, emit func(Result)) error
+}
+
+// Scope is what an active method should cover during a scan.
+type Scope struct {
+	// Targets are the IPv4 addresses enumerated from the scan ranges; empty
+	// when the scan falls back to the local subnet.
+	Targets []string
+	// Subnets are the local subnets, for broadcast-based methods.
+	Subnets []net.IPNet
+}
+
+// Result is one candidate found by a method. Only IP is required; Details
+// are free-form hints (e.g. vendor or device instance) recorded in the scan
+// event log. The runner fills in Method.
+type Result struct {
+	IP      string            `json:"ip"`
+	Method  string            `json:"method"`
+	Details map[string]string `json:"details,omitempty"`
+}
+
+var (
+	registryMu sync.RWMutex
+	methods    = map[string]Method{}
+)
+
+// Register adds a method to the registry. It is meant to be called from
+// init() and panics on an invalid or duplicate name.
+func Register(m Method) {
+	name := m.Name()
+	if name == "" || name != strings.ToLower(strings.TrimSpace(name)) {
+		panic(fmt.Sprintf("discovery: invalid method name %q", name))
+	}
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	if _, exists := methods[name]; exists {
+		panic(fmt.Sprintf("discovery: method %q registered twice", name))
+	}
+	methods[name] = m
+}
+
+// Lookup returns a registered method by name.
+func Lookup(name string) (Method, bool) {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	m, ok := methods[name]
+	return m, ok
+}
+
+// Methods returns all registered methods sorted by name.
+func Methods() []Method {
+	registryMu.RLock()
+	out := make([]Method, 0, len(methods))
+	for _, m := range methods {
+		out = append(out, m)
+	}
+	registryMu.RUnlock()
+	sort.Slice(out, func(i, j int) bool { return out[i].Name() < out[j].Name() })
+	return out
+}
+
+// unregister removes a method; used by tests.
+func unregister(name string) {
+	registryMu.Lock()
+	delete(methods, name)
+	registryMu.Unlock()
+}
@@ -0,0 +1,229 @@
+package discovery
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// PassiveRepeatWindow is how long a passive method's repeat sightings of the
+// same IP are ignored, matching the built-in live listeners.
+const PassiveRepeatWindow = 10 * time.Minute
+
+// normalizeResult checks a result and stamps it with the method name. Only
+// IPv4 candidates are accepted, like the rest of discovery.
+func normalizeResult(method string, r Result) (Result, bool) {
+	ip := net.ParseIP(strings.TrimSpace(r.IP))
+	if ip == nil || ip.To4() == nil || ip.IsUnspecified() || ip.IsLoopback() {
+		return Result{}, false
+	}
+	r.IP = ip.To4().String()
+	r.Method = method
+	return r, true
+}
+
+// runSafely calls fn and turns a panic in a third-party method into an error
+// so a broken probe cannot take the agent down.
+func runSafely(name string, fn func() error) (err error) {
+	defer func() {
+		if p := recover(); p != nil {
+			err = fmt.Errorf("%s: panic: %v", name, p)
+		}
+	}()
+	return fn()
+}
+
+// RunActive runs the enabled active methods concurrently for one scan and
+// returns once all of them finish. Each IP reaches handle at most once per
+// run, whichever method finds it first. Methods that fail or have invalid
+// options are reported in the returned map.
+func RunActive(ctx context.Context, settings map[string]Settings, scope Scope, handle func(Result)) map[string]error {
+	var (
+		wg     sync.WaitGroup
+		mu     sync.Mutex
+		seen   = make(map[string]bool)
+		errs   = make(map[string]error)
+		record = func(name string, err error) {
+			mu.Lock()
+			errs[name] = err
+			mu.Unlock()
+		}
+	)
+	for _, m := range Methods() {
+		active, ok := m.(ActiveMethod)
+		if !ok || !settings[m.Name()].Enabled {
+			continue
+		}
+		cfg, err := NewConfig(m, settings[m.Name()].Options)
+		if err != nil {
+			record(m.Name(), err)
+			continue
+		}
+		wg.Add(1)
+		go func(active ActiveMethod, cfg Config) {
+			defer wg.Done()
+			name := active.Name()
+			emit := func(r Result) {
+				r, ok := normalizeResult(name, r)
+				if !ok {
+					return
+				}
+				mu.Lock()
+				dup := seen[r.IP]
+				seen[r.IP] = true
+				mu.Unlock()
+				if !dup {
+					handle(r)
+				}
+			}
+			err := runSafely(name, func() error { return active.Discover(ctx, scope, cfg, emit) })
+			if err != nil && ctx.Err() == nil {
+				record(name, err)
+			}
+		}(active, cfg)
+	}
+	wg.Wait()
+	return errs
+}
+
+// Listeners keeps the enabled passive methods running in the background.
+type Listeners struct {
+	handle  func(Result)
+	onError func(method string, err error)
+
+	mu      sync.Mutex
+	running map[string]*listener
+}
+
+type listener struct {
+	settings Settings
+	cancel   context.CancelFunc
+	done     chan struct{}
+}
+
+// NewListeners creates a passive listener manager. handle receives each new
+// candidate; onError, if set, is told when a listener fails or exits early.
+func NewListeners(handle func(Result), onError func(method string, err error)) *Listeners {
+	return &Listeners{handle: handle, onError: onError, running: make(map[string]*listener)}
+}
+
+// Apply starts enabled passive methods, stops disabled ones and restarts
+// those whose options changed.
+func (l *Listeners) Apply(settings map[string]Settings) {
+	l.mu.Lock()
+	var stopped []*listener
+	for name, ln := range l.running {
+		s := settings[name]
+		if !s.Enabled || !reflect.DeepEqual(s, ln.settings) {
+			stopped = append(stopped, l.stopLocked(name))
+		}
+	}
+	l.mu.Unlock()
+	// Let stopped listeners release their sockets before restarting them
+	for _, ln := range stopped {
+		<-ln.done
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for _, m := range Methods() {
+		passive, ok := m.(PassiveMethod)
+		s := settings[m.Name()]
+		if !ok || !s.Enabled {
+			continue
+		}
+		if _, running := l.running[m.Name()]; running {
+			continue
+		}
+		cfg, err := NewConfig(m, s.Options)
+		if err != nil {
+			l.reportError(m.Name(), err)
+			continue
+		}
+		l.startLocked(passive, s, cfg)
+	}
+}
+
+func (l *Listeners) startLocked(m PassiveMethod, s Settings, cfg Config) {
+	ctx, cancel := context.WithCancel(context.Background())
+	ln := &listener{settings: s, cancel: cancel, done: make(chan struct{})}
+	l.running[m.Name()] = ln
+
+	var seenMu sync.Mutex
+	seen := make(map[string]time.Time)
+	emit := func(r Result) {
+		r, ok := normalizeResult(m.Name(), r)
+		if !ok {
+			return
+		}
+		seenMu.Lock()
+		last, dup := seen[r.IP]
+		if dup && time.Since(last) < PassiveRepeatWindow {
+			seenMu.Unlock()
+			return
+		}
+		seen[r.IP] = time.Now()
+		seenMu.Unlock()
+		l.handle(r)
+	}
+	go func() {
+		defer close(ln.done)
+		err := runSafely(m.Name(), func() error { return m.Listen(ctx, cfg, emit) })
+		if ctx.Err() == nil {
+			if err == nil {
+				err = fmt.Errorf("%s: listener exited", m.Name())
+			}
+			l.reportError(m.Name(), err)
+			l.mu.Lock()
+			if l.running[m.Name()] == ln {
+				delete(l.running, m.Name())
+			}
+			l.mu.Unlock()
+		}
+	}()
+}
+
+// stopLocked cancels a listener and forgets it; the caller waits on its
+// done channel after releasing the lock.
+func (l *Listeners) stopLocked(name string) *listener {
+	ln := l.running[name]
+	delete(l.running, name)
+	ln.cancel()
+	return ln
+}
+
+func (l *Listeners) reportError(name string, err error) {
+	if l.onError != nil {
+		l.onError(name, err)
+	}
+}
+
+// Running returns the names of the passive methods currently listening.
+func (l *Listeners) Running() []string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	names := make([]string, 0, len(l.running))
+	for name := range l.running {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// StopAll stops every listener and waits for them to exit.
+func (l *Listeners) StopAll() {
+	l.mu.Lock()
+	var stopped []*listener
+	for name := range l.running {
+		stopped = append(stopped, l.stopLocked(name))
+	}
+	l.mu.Unlock()
+	for _, ln := range stopped {
+		<-ln.done
+	}
+}
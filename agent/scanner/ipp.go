@@ -0,0 +1,586 @@
+package scanner
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"printmaster/agent/devicetls"
+	"printmaster/agent/scanscope"
+)
+
+// IPP (RFC 8011) client for printers that ship with SNMP turned off. It sends
+// a single Get-Printer-Attributes request to port 631 and extracts the
+// identity, supply levels and page counters that IPP Everywhere printers
+// report.
+
+// DefaultIPPPort is the standard IPP port.
+const DefaultIPPPort = 631
+
+// ippPaths are the printer URI paths tried in order. IPP Everywhere printers
+// use /ipp/print; older devices answer on /ipp or the root.
+var ippPaths = []string{"/ipp/print", "/ipp", "/"}
+
+// ippRequestedAttributes is what Get-Printer-Attributes asks for.
+var ippRequestedAttributes = []string{
+	"printer-make-and-model", "printer-device-id", "printer-serial-number",
+	"printer-name", "printer-info", "printer-location", "printer-uuid",
+	"printer-firmware-string-version", "printer-more-info",
+	"printer-state", "printer-state-reasons", "printer-state-message",
+	"color-supported", "sides-supported",
+	"marker-names", "marker-colors", "marker-types", "marker-levels",
+	"marker-high-levels", "marker-low-levels",
+	"printer-supply", "printer-supply-description",
+	"printer-impressions-completed", "printer-impressions-completed-col",
+	"printer-media-sheets-completed", "printer-pages-completed",
+}
+
+// IPP value and delimiter tags (RFC 8010 section 3.5).
+const (
+	ippTagOperation     = 0x01
+	ippTagEnd           = 0x03
+	ippTagInteger       = 0x21
+	ippTagBoolean       = 0x22
+	ippTagEnum          = 0x23
+	ippTagBegCollection = 0x34
+	ippTagTextLang      = 0x35
+	ippTagNameLang      = 0x36
+	ippTagEndCollection = 0x37
+	ippTagURI           = 0x45
+	ippTagKeyword       = 0x44
+	ippTagCharset       = 0x47
+	ippTagLanguage      = 0x48
+	ippTagMemberName    = 0x4A
+
+	ippOpGetPrinterAttributes = 0x000B
+)
+
+// IPPAttributes maps attribute names to their values. Values are int,
+// bool, string or, for collections, IPPAttributes.
+type IPPAttributes map[string][]interface{}
+
+// String returns the first value of name as a string.
+func (a IPPAttributes) String(name string) string {
+	for _, v := range a[name] {
+		if s, ok := v.(string); ok {
+			return strings.TrimSpace(s)
+		}
+	}
+	return ""
+}
+
+// Strings returns all string values of name.
+func (a IPPAttributes) Strings(name string) []string {
+	var out []string
+	for _, v := range a[name] {
+		if s, ok := v.(string); ok {
+			out = append(out, strings.TrimSpace(s))
+		}
+	}
+	return out
+}
+
+// Int returns the first integer value of name.
+func (a IPPAttributes) Int(name string) (int, bool) {
+	for _, v := range a[name] {
+		if i, ok := v.(int); ok {
+			return i, true
+		}
+	}
+	return 0, false
+}
+
+// Ints returns all integer values of name.
+func (a IPPAttributes) Ints(name string) []int {
+	var out []int
+	for _, v := range a[name] {
+		if i, ok := v.(int); ok {
+			out = append(out, i)
+		}
+	}
+	return out
+}
+
+// IPPSupply is one marker or supply as reported over IPP.
+type IPPSupply struct {
+	Name  string `json:"name"`
+	Type  string `json:"type,omitempty"`  // toner, ink, ...
+	Color string `json:"color,omitempty"` // #RRGGBB or a colorant name
+	// Level and Max follow the Printer-MIB conventions: -1 other, -2
+	// unknown, -3 some remaining. Max is 100 when levels are percentages.
+	Level int `json:"level"`
+	Max   int `json:"max"`
+}
+
+// IPPResult holds the printer attributes read over IPP.
+type IPPResult struct {
+	IP           string      `json:"ip"`
+	URI          string      `json:"uri"`
+	MakeAndModel string      `json:"make_and_model,omitempty"`
+	Manufacturer string      `json:"manufacturer,omitempty"`
+	Model        string      `json:"model,omitempty"`
+	Serial       string      `json:"serial,omitempty"`
+	Name         string      `json:"name,omitempty"`
+	Info         string      `json:"info,omitempty"`
+	Location     string      `json:"location,omitempty"`
+	UUID         string      `json:"uuid,omitempty"`
+	Firmware     string      `json:"firmware,omitempty"`
+	WebUIURL     string      `json:"web_ui_url,omitempty"`
+	State        string      `json:"state,omitempty"` // idle, processing, stopped
+	StateReasons []string    `json:"state_reasons,omitempty"`
+	StateMessage string      `json:"state_message,omitempty"`
+	Color        bool        `json:"color,omitempty"`
+	Duplex       bool        `json:"duplex,omitempty"`
+	Supplies     []IPPSupply `json:"supplies,omitempty"`
+	// Impressions is the lifetime impression count; Mono and Color split it
+	// when the printer reports printer-impressions-completed-col.
+	Impressions      int `json:"impressions,omitempty"`
+	MonoImpressions  int `json:"mono_impressions,omitempty"`
+	ColorImpressions int `json:"color_impressions,omitempty"`
+	Sheets           int `json:"sheets,omitempty"`
+
+	Attributes IPPAttributes `json:"-"`
+}
+
+// QueryIPP reads the printer attributes of ip over IPP on port 631, first
+// over plain HTTP and then over TLS (ipps), trying the common printer URI
+// paths. timeout bounds each request.
+func QueryIPP(ctx context.Context, ip string, timeout time.Duration) (*IPPResult, error) {
+	if err := scanscope.Check(ip, "ipp"); err != nil {
+		return nil, err
+	}
+	if timeout <= 0 {
+		timeout = 5 * time.Second
+	}
+	host := net.JoinHostPort(ip, strconv.Itoa(DefaultIPPPort))
+	var lastErr error
+	for _, scheme := range []string{"http", "https"} {
+		for _, path := range ippPaths {
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+			res, err := QueryIPPURL(ctx, scheme+"://"+host+path, timeout)
+			if err == nil {
+				res.IP = ip
+				return res, nil
+			}
+			lastErr = err
+			var ne net.Error
+			if errors.As(err, &ne) && ne.Timeout() {
+				// Nothing answered; other paths will not fare better
+				return nil, err
+			}
+			if errors.Is(err, errIPPTransport) {
+				break // try the next scheme
+			}
+		}
+	}
+	return nil, lastErr
+}
+
+// errIPPTransport marks failures below IPP (connection reset, TLS, non-IPP
+// HTTP response) where other paths on the same scheme will fail too.
+var errIPPTransport = errors.New("ipp transport error")
+
+// QueryIPPURL sends Get-Printer-Attributes to an http(s) printer URL.
+func QueryIPPURL(ctx context.Context, printerURL string, timeout time.Duration) (*IPPResult, error) {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	// The printer-uri names the ipp:// form of the URL
+	uri := printerURL
+	if strings.HasPrefix(uri, "https://") {
+		uri = "ipps://" + strings.TrimPrefix(uri, "https://")
+	} else if strings.HasPrefix(uri, "http://") {
+		uri = "ipp://" + strings.TrimPrefix(uri, "http://")
+	}
+	body := encodeGetPrinterAttributes(uri, 1)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, printerURL, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/ipp")
+
+	// Printers use self-signed certificates; devicetls skips verification
+	// and applies per-device TLS overrides
+	transport := devicetls.Transport(&http.Transport{})
+	client := &http.Client{Transport: transport, Timeout: timeout}
+	defer transport.CloseIdleConnections()
+	resp, err := client.Do(req)
+	if err != nil {
+		var ne net.Error
+		if errors.As(err, &ne) && ne.Timeout() {
+			return nil, err
+		}
+		return nil, fmt.Errorf("%w: %v", errIPPTransport, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusUpgradeRequired {
+		return nil, fmt.Errorf("%w: %s requires TLS", errIPPTransport, printerURL)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("ipp: HTTP %d from %s", resp.StatusCode, printerURL)
+	}
+	if ct := resp.Header.Get("Content-Type"); ct != "" && !strings.HasPrefix(ct, "application/ipp") {
+		return nil, fmt.Errorf("%w: unexpected content type %q", errIPPTransport, ct)
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return nil, err
+	}
+	status, attrs, err := decodeIPPResponse(data)
+	if err != nil {
+		return nil, err
+	}
+	// 0x0000-0x00FF are successful-ok variants (some attributes ignored, ...)
+	if status > 0x00FF {
+		return nil, fmt.Errorf("ipp: status 0x%04x from %s", status, printerURL)
+	}
+	res := ParseIPPAttributes(attrs)
+	res.URI = uri
+	return res, nil
+}
+
+// encodeGetPrinterAttributes builds an IPP/1.1 Get-Printer-Attributes request.
+func encodeGetPrinterAttributes(printerURI string, requestID uint32) []byte {
+	var b bytes.Buffer
+	b.Write([]byte{1, 1}) // IPP/1.1 is answered by every IPP printer
+	binary.Write(&b, binary.BigEndian, uint16(ippOpGetPrinterAttributes))
+	binary.Write(&b, binary.BigEndian, requestID)
+	b.WriteByte(ippTagOperation)
+	writeIPPAttr(&b, ippTagCharset, "attributes-charset", "utf-8")
+	writeIPPAttr(&b, ippTagLanguage, "attributes-natural-language", "en")
+	writeIPPAttr(&b, ippTagURI, "printer-uri", printerURI)
+	for i, name := range ippRequestedAttributes {
+		attr := "requested-attributes"
+		if i > 0 {
+			attr = "" // additional value of the same attribute
+		}
+		writeIPPAttr(&b, ippTagKeyword, attr, name)
+	}
+	b.WriteByte(ippTagEnd)
+	return b.Bytes()
+}
+
+func writeIPPAttr(b *bytes.Buffer, tag byte, name, value string) {
+	b.WriteByte(tag)
+	binary.Write(b, binary.BigEndian, uint16(len(name)))
+	b.WriteString(name)
+	binary.Write(b, binary.BigEndian, uint16(len(value)))
+	b.WriteString(value)
+}
+
+// ippReader walks the attribute encoding of an IPP message.
+type ippReader struct {
+	data []byte
+	pos  int
+}
+
+var errIPPShort = errors.New("ipp: truncated response")
+
+func (r *ippReader) byte() (byte, error) {
+	if r.pos >= len(r.data) {
+		return 0, errIPPShort
+	}
+	b := r.data[r.pos]
+	r.pos++
+	return b, nil
+}
+
+func (r *ippReader) chunk() ([]byte, error) {
+	if r.pos+2 > len(r.data) {
+		return nil, errIPPShort
+	}
+	n := int(binary.BigEndian.Uint16(r.data[r.pos:]))
+	r.pos += 2
+	if r.pos+n > len(r.data) {
+		return nil, errIPPShort
+	}
+	v := r.data[r.pos : r.pos+n]
+	r.pos += n
+	return v, nil
+}
+
+// decodeIPPResponse returns the status code and the attributes of every
+// group merged into one map (a printer response has only operation and
+// printer attributes).
+func decodeIPPResponse(data []byte) (int, IPPAttributes, error) {
+	if len(data) < 8 {
+		return 0, nil, errIPPShort
+	}
+	status := int(binary.BigEndian.Uint16(data[2:4]))
+	r := &ippReader{data: data, pos: 8}
+	attrs := IPPAttributes{}
+	var last string
+	for {
+		tag, err := r.byte()
+		if err != nil {
+			return 0, nil, err
+		}
+		if tag == ippTagEnd {
+			return status, attrs, nil
+		}
+		if tag < 0x10 {
+			last = "" // start of a new attribute group
+			continue
+		}
+		nameBytes, err := r.chunk()
+		if err != nil {
+			return 0, nil, err
+		}
+		value, err := r.value(tag)
+		if err != nil {
+			return 0, nil, err
+		}
+		if len(nameBytes) > 0 {
+			last = string(nameBytes)
+		}
+		if last != "" && value != nil {
+			attrs[last] = append(attrs[last], value)
+		}
+	}
+}
+
+// value reads the value that follows a name. Collections are read in full.
+func (r *ippReader) value(tag byte) (interface{}, error) {
+	raw, err := r.chunk()
+	if err != nil {
+		return nil, err
+	}
+	switch tag {
+	case ippTagInteger, ippTagEnum:
+		if len(raw) != 4 {
+			return nil, nil
+		}
+		return int(int32(binary.BigEndian.Uint32(raw))), nil
+	case ippTagBoolean:
+		if len(raw) != 1 {
+			return nil, nil
+		}
+		return raw[0] != 0, nil
+	case ippTagTextLang, ippTagNameLang:
+		inner := &ippReader{data: raw}
+		if _, err := inner.chunk(); err != nil { // language
+			return nil, nil
+		}
+		text, err := inner.chunk()
+		if err != nil {
+			return nil, nil
+		}
+		return string(text), nil
+	case ippTagBegCollection:
+		return r.collection()
+	}
+	switch {
+	case tag < 0x20: // out-of-band: unknown, no-value, ...
+		return nil, nil
+	case tag >= 0x30 && tag < 0x34 && tag != 0x30:
+		return nil, nil // dateTime, resolution, rangeOfInteger: not used
+	case tag == 0x7F:
+		return nil, nil // extension tag
+	}
+	// octetString and the character-string types
+	return string(raw), nil
+}
+
+// collection reads member attributes up to the matching endCollection.
+func (r *ippReader) collection() (IPPAttributes, error) {
+	members := IPPAttributes{}
+	var member string
+	for {
+		tag, err := r.byte()
+		if err != nil {
+			return nil, err
+		}
+		if _, err := r.chunk(); err != nil { // name, always empty here
+			return nil, err
+		}
+		switch tag {
+		case ippTagEndCollection:
+			if _, err := r.chunk(); err != nil {
+				return nil, err
+			}
+			return members, nil
+		case ippTagMemberName:
+			name, err := r.chunk()
+			if err != nil {
+				return nil, err
+			}
+			member = string(name)
+			continue
+		}
+		value, err := r.value(tag)
+		if err != nil {
+			return nil, err
+		}
+		if member != "" && value != nil {
+			members[member] = append(members[member], value)
+		}
+	}
+}
+
+// ParseIPPAttributes extracts the fields PrintMaster uses from raw printer
+// attributes.
+func ParseIPPAttributes(attrs IPPAttributes) *IPPResult {
+	res := &IPPResult{
+		MakeAndModel: attrs.String("printer-make-and-model"),
+		Name:         attrs.String("printer-name"),
+		Info:         attrs.String("printer-info"),
+		Location:     attrs.String("printer-location"),
+		UUID:         strings.TrimPrefix(attrs.String("printer-uuid"), "urn:uuid:"),
+		Firmware:     attrs.String("printer-firmware-string-version"),
+		WebUIURL:     attrs.String("printer-more-info"),
+		StateMessage: attrs.String("printer-state-message"),
+		Attributes:   attrs,
+	}
+	deviceID := parseDeviceID(attrs.String("printer-device-id"))
+	res.Manufacturer = firstNonEmpty(deviceID["MFG"], deviceID["MANUFACTURER"])
+	res.Model = firstNonEmpty(deviceID["MDL"], deviceID["MODEL"])
+	res.Serial = firstNonEmpty(attrs.String("printer-serial-number"), deviceID["SN"], deviceID["SERN"], deviceID["SERIALNUMBER"])
+	if res.Manufacturer == "" && res.MakeAndModel != "" {
+		res.Manufacturer = strings.Fields(res.MakeAndModel)[0]
+	}
+	if res.Model == "" && res.MakeAndModel != "" {
+		res.Model = strings.TrimSpace(strings.TrimPrefix(res.MakeAndModel, res.Manufacturer))
+		if res.Model == "" {
+			res.Model = res.MakeAndModel
+		}
+	}
+	// Strip the manufacturer from the model when the device repeats it
+	if prefix := strings.ToLower(res.Manufacturer) + " "; len(res.Model) > len(prefix) && strings.HasPrefix(strings.ToLower(res.Model), prefix) {
+		res.Model = strings.TrimSpace(res.Model[len(prefix):])
+	}
+
+	if state, ok := attrs.Int("printer-state"); ok {
+		switch state {
+		case 3:
+			res.State = "idle"
+		case 4:
+			res.State = "processing"
+		case 5:
+			res.State = "stopped"
+		}
+	}
+	for _, reason := range attrs.Strings("printer-state-reasons") {
+		if reason != "" && reason != "none" {
+			res.StateReasons = append(res.StateReasons, reason)
+		}
+	}
+	if v, ok := attrs["color-supported"]; ok && len(v) > 0 {
+		res.Color, _ = v[0].(bool)
+	}
+	for _, side := range attrs.Strings("sides-supported") {
+		if strings.HasPrefix(side, "two-sided") {
+			res.Duplex = true
+		}
+	}
+
+	res.Supplies = parseIPPSupplies(attrs)
+
+	if v, ok := attrs.Int("printer-impressions-completed"); ok && v >= 0 {
+		res.Impressions = v
+	} else if v, ok := attrs.Int("printer-pages-completed"); ok && v >= 0 {
+		res.Impressions = v
+	}
+	if v, ok := attrs.Int("printer-media-sheets-completed"); ok && v >= 0 {
+		res.Sheets = v
+	}
+	for _, c := range attrs["printer-impressions-completed-col"] {
+		col, ok := c.(IPPAttributes)
+		if !ok {
+			continue
+		}
+		if v, ok := col.Int("monochrome"); ok && v >= 0 {
+			res.MonoImpressions = v
+		}
+		if v, ok := col.Int("full-color"); ok && v >= 0 {
+			res.ColorImpressions = v
+		}
+		if res.Impressions == 0 && res.MonoImpressions+res.ColorImpressions > 0 {
+			res.Impressions = res.MonoImpressions + res.ColorImpressions
+		}
+	}
+	return res
+}
+
+// parseIPPSupplies reads the CUPS marker-* attributes and falls back to the
+// PWG printer-supply strings.
+func parseIPPSupplies(attrs IPPAttributes) []IPPSupply {
+	names := attrs.Strings("marker-names")
+	levels := attrs.Ints("marker-levels")
+	if len(names) > 0 && len(levels) == len(names) {
+		colors := attrs.Strings("marker-colors")
+		types := attrs.Strings("marker-types")
+		highs := attrs.Ints("marker-high-levels")
+		out := make([]IPPSupply, len(names))
+		for i, name := range names {
+			s := IPPSupply{Name: name, Level: levels[i], Max: 100}
+			if i < len(colors) {
+				s.Color = colors[i]
+			}
+			if i < len(types) {
+				s.Type = types[i]
+			}
+			if i < len(highs) && highs[i] > 0 {
+				s.Max = highs[i]
+			}
+			out[i] = s
+		}
+		return out
+	}
+
+	descs := attrs.Strings("printer-supply-description")
+	var out []IPPSupply
+	for i, raw := range attrs.Strings("printer-supply") {
+		fields := map[string]string{}
+		for _, part := range strings.Split(raw, ";") {
+			if k, v, ok := strings.Cut(part, "="); ok {
+				fields[strings.ToLower(strings.TrimSpace(k))] = strings.TrimSpace(v)
+			}
+		}
+		level, err := strconv.Atoi(fields["level"])
+		if err != nil {
+			continue
+		}
+		s := IPPSupply{Type: fields["type"], Color: fields["colorantname"], Level: level, Max: 100}
+		if max, err := strconv.Atoi(fields["maxcapacity"]); err == nil && max > 0 {
+			s.Max = max
+		}
+		if i < len(descs) {
+			s.Name = descs[i]
+		}
+		if s.Name == "" {
+			s.Name = strings.TrimSpace(s.Color + " " + s.Type)
+		}
+		out = append(out, s)
+	}
+	return out
+}
+
+// parseDeviceID splits an IEEE 1284 device ID ("MFG:HP;MDL:LaserJet;SN:X;").
+func parseDeviceID(id string) map[string]string {
+	out := map[string]string{}
+	for _, part := range strings.Split(id, ";") {
+		if k, v, ok := strings.Cut(part, ":"); ok {
+			out[strings.ToUpper(strings.TrimSpace(k))] = strings.TrimSpace(v)
+		}
+	}
+	return out
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
@@ -0,0 +1,212 @@
+package scanner
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// ippResponse builds IPP responses for tests.
+type ippResponse struct{ bytes.Buffer }
+
+func newIPPResponse(status uint16) *ippResponse {
+	r := &ippResponse{}
+	r.Write([]byte{1, 1})
+	binary.Write(r, binary.BigEndian, status)
+	binary.Write(r, binary.BigEndian, uint32(1))
+	r.WriteByte(ippTagOperation)
+	writeIPPAttr(&r.Buffer, ippTagCharset, "attributes-charset", "utf-8")
+	r.WriteByte(0x04) // printer-attributes-tag
+	return r
+}
+
+func (r *ippResponse) str(tag byte, name string, values ...string) *ippResponse {
+	for i, v := range values {
+		if i > 0 {
+			name = ""
+		}
+		writeIPPAttr(&r.Buffer, tag, name, v)
+	}
+	return r
+}
+
+func (r *ippResponse) ints(tag byte, name string, values ...int) *ippResponse {
+	for i, v := range values {
+		if i > 0 {
+			name = ""
+		}
+		r.WriteByte(tag)
+		binary.Write(r, binary.BigEndian, uint16(len(name)))
+		r.WriteString(name)
+		binary.Write(r, binary.BigEndian, uint16(4))
+		binary.Write(r, binary.BigEndian, int32(v))
+	}
+	return r
+}
+
+func (r *ippResponse) boolean(name string, v bool) *ippResponse {
+	b := byte(0)
+	if v {
+		b = 1
+	}
+	r.WriteByte(ippTagBoolean)
+	binary.Write(r, binary.BigEndian, uint16(len(name)))
+	r.WriteString(name)
+	binary.Write(r, binary.BigEndian, uint16(1))
+	r.WriteByte(b)
+	return r
+}
+
+// impressionsCol writes printer-impressions-completed-col.
+func (r *ippResponse) impressionsCol(mono, color int) *ippResponse {
+	writeIPPAttr(&r.Buffer, ippTagBegCollection, "printer-impressions-completed-col", "")
+	writeIPPAttr(&r.Buffer, ippTagMemberName, "", "monochrome")
+	r.ints(ippTagInteger, "", mono)
+	writeIPPAttr(&r.Buffer, ippTagMemberName, "", "full-color")
+	r.ints(ippTagInteger, "", color)
+	writeIPPAttr(&r.Buffer, ippTagEndCollection, "", "")
+	return r
+}
+
+func (r *ippResponse) end() []byte {
+	r.WriteByte(ippTagEnd)
+	return r.Bytes()
+}
+
+func ippServer(t *testing.T, body []byte, seen *[]byte) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/ipp/print" || r.Header.Get("Content-Type") != "application/ipp" {
+			http.NotFound(w, r)
+			return
+		}
+		if seen != nil {
+			*seen, _ = io.ReadAll(r.Body)
+		}
+		w.Header().Set("Content-Type", "application/ipp")
+		w.Write(body)
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestQueryIPPURLParsesPrinterAttributes(t *testing.T) {
+	body := newIPPResponse(0x0000).
+		str(0x41, "printer-make-and-model", "HP Color LaserJet Pro M454dw").
+		str(0x41, "printer-device-id", "MFG:HP;MDL:HP Color LaserJet Pro M454dw;CMD:PCL,PDF;SN:VNB3K12345;").
+		str(0x42, "printer-name", "HP454").
+		str(0x41, "printer-location", "2nd floor").
+		str(ippTagURI, "printer-uuid", "urn:uuid:564e4233-4b31-3233-3435-a0b3ccde0001").
+		str(ippTagURI, "printer-more-info", "http://192.0.2.10/").
+		ints(ippTagEnum, "printer-state", 3).
+		str(ippTagKeyword, "printer-state-reasons", "toner-low-warning").
+		boolean("color-supported", true).
+		str(ippTagKeyword, "sides-supported", "one-sided", "two-sided-long-edge").
+		str(0x42, "marker-names", "Black Cartridge", "Cyan Cartridge").
+		str(0x42, "marker-colors", "#000000", "#00FFFF").
+		str(ippTagKeyword, "marker-types", "toner", "toner").
+		ints(ippTagInteger, "marker-levels", 80, -3).
+		ints(ippTagInteger, "printer-impressions-completed", 12345).
+		impressionsCol(10000, 2345).
+		end()
+	var request []byte
+	srv := ippServer(t, body, &request)
+
+	res, err := QueryIPPURL(context.Background(), srv.URL+"/ipp/print", 2*time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Contains(request, []byte("printer-uri")) || !bytes.Contains(request, []byte("marker-levels")) {
+		t.Fatal("request is missing the printer-uri or requested attributes")
+	}
+	if res.Manufacturer != "HP" || res.Model != "Color LaserJet Pro M454dw" || res.Serial != "VNB3K12345" {
+		t.Fatalf("identity: %+v", res)
+	}
+	if res.Location != "2nd floor" || res.UUID != "564e4233-4b31-3233-3435-a0b3ccde0001" || res.WebUIURL != "http://192.0.2.10/" {
+		t.Fatalf("details: %+v", res)
+	}
+	if res.State != "idle" || len(res.StateReasons) != 1 || !res.Color || !res.Duplex {
+		t.Fatalf("state/capabilities: %+v", res)
+	}
+	if len(res.Supplies) != 2 || res.Supplies[0].Level != 80 || res.Supplies[1].Level != -3 || res.Supplies[1].Color != "#00FFFF" {
+		t.Fatalf("supplies: %+v", res.Supplies)
+	}
+	if res.Impressions != 12345 || res.MonoImpressions != 10000 || res.ColorImpressions != 2345 {
+		t.Fatalf("counters: %+v", res)
+	}
+}
+
+func TestParseIPPAttributesPrinterSupplyFallback(t *testing.T) {
+	attrs := IPPAttributes{
+		"printer-make-and-model":     {"Brother HL-L2350DW series"},
+		"printer-supply":             {"index=1;class=supplyThatIsConsumed;type=toner;unit=percent;maxcapacity=100;level=40;colorantname=black;"},
+		"printer-supply-description": {"Black Toner"},
+	}
+	res := ParseIPPAttributes(attrs)
+	if res.Manufacturer != "Brother" || res.Model != "HL-L2350DW series" {
+		t.Fatalf("identity: %+v", res)
+	}
+	if len(res.Supplies) != 1 || res.Supplies[0].Name != "Black Toner" || res.Supplies[0].Level != 40 {
+		t.Fatalf("supplies: %+v", res.Supplies)
+	}
+}
+
+func TestQueryIPPURLErrors(t *testing.T) {
+	srv := ippServer(t, newIPPResponse(0x0400).end(), nil)
+	if _, err := QueryIPPURL(context.Background(), srv.URL+"/ipp/print", time.Second); err == nil {
+		t.Fatal("expected an error for client-error-bad-request")
+	}
+	if _, err := QueryIPPURL(context.Background(), srv.URL+"/other", time.Second); err == nil {
+		t.Fatal("expected an error for HTTP 404")
+	}
+	truncated := ippServer(t, newIPPResponse(0).str(0x41, "printer-make-and-model", "X").Bytes()[:20], nil)
+	if _, err := QueryIPPURL(context.Background(), truncated.URL+"/ipp/print", time.Second); err == nil {
+		t.Fatal("expected an error for a truncated response")
+	}
+}
+
+func TestDetectFuncFallsBackToIPP(t *testing.T) {
+	originalNewSNMPClient := NewSNMPClientFunc
+	defer func() { NewSNMPClientFunc = originalNewSNMPClient }()
+	NewSNMPClientFunc = func(cfg *SNMPConfig, target string, timeoutSeconds int) (SNMPClient, error) {
+		return nil, io.ErrUnexpectedEOF // SNMP blocked
+	}
+
+	ipp := &IPPResult{MakeAndModel: "Canon iR-ADV C3826", Manufacturer: "Canon"}
+	calls := 0
+	cfg := DetectorConfig{SNMPTimeout: 1, IPPFunc: func(ctx context.Context, ip string, timeout time.Duration) (*IPPResult, error) {
+		calls++
+		return ipp, nil
+	}}
+	detect := DetectFunc(cfg)
+
+	info, isPrinter, _ := detect(context.Background(), ScanJob{IP: "10.0.0.20"}, []int{80, 631})
+	if !isPrinter || info != ipp {
+		t.Fatalf("expected IPP result, got %v %v", info, isPrinter)
+	}
+	// Without port 631 open IPP is not tried
+	if _, isPrinter, _ := detect(context.Background(), ScanJob{IP: "10.0.0.21"}, []int{80}); isPrinter || calls != 1 {
+		t.Fatalf("IPP queried without port 631 (calls=%d)", calls)
+	}
+
+	// With SNMP disabled, IPP is used directly
+	cfg.SNMPDisabled = true
+	NewSNMPClientFunc = func(cfg *SNMPConfig, target string, timeoutSeconds int) (SNMPClient, error) {
+		t.Fatal("SNMP used while disabled")
+		return nil, nil
+	}
+	if _, isPrinter, _ := DetectFunc(cfg)(context.Background(), ScanJob{IP: "10.0.0.22"}, []int{631}); !isPrinter {
+		t.Fatal("expected IPP detection with SNMP disabled")
+	}
+
+	// Deep scan passes IPP results through
+	out, err := DeepScanFunc(cfg)(context.Background(), DetectionResult{Job: ScanJob{IP: "10.0.0.22"}, IsPrinter: true, Info: ipp})
+	if err != nil || out != ipp {
+		t.Fatalf("deep scan: %v %v", out, err)
+	}
+}
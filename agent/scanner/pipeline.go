@@ -7,6 +7,8 @@ import (
 	"strconv"
 	"sync"
 	"time"
+
+	"printmaster/agent/scanscope"
 )
 
 // ScanJob describes a single IP scan request.
@@ -96,11 +98,28 @@ func StartLivenessPool(ctx context.Context, cfg ScannerConfig, jobs <-chan ScanJ
 					if !ok {
 						return
 					}
-					open, err := probe(j.IP, cfg.LivenessPorts, cfg.LivenessTimeout)
+					if err := scanscope.Check(j.IP, "liveness"); err != nil {
+						select {
+						case <-ctx.Done():
+							return
+						case out <- LivenessResult{Job: j, Err: err}:
+						}
+						continue
+					}
+					ports := cfg.LivenessPorts
+					override, overridden := PortOverrideFor(j.IP)
+					if override.HTTPPort > 0 {
+						ports = append([]int{override.HTTPPort}, ports...)
+					}
+					open, err := probe(j.IP, ports, cfg.LivenessTimeout)
 					res := LivenessResult{Job: j}
 					if err == nil && len(open) > 0 {
 						res.Alive = true
 						res.OpenPorts = open
+					} else if overridden {
+						// Forwarded hosts often expose only the UDP SNMP
+						// pinhole, which a TCP probe cannot see.
+						res.Alive = true
 					} else {
 						res.Alive = false
 						res.Err = err
@@ -0,0 +1,100 @@
+package scanner
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// DefaultSNMPPort is the standard SNMP agent port.
+const DefaultSNMPPort = 161
+
+// PortOverride holds non-standard ports for a device that is only reachable
+// through forwarded ports (NAT pinholes). Zero means the standard port.
+type PortOverride struct {
+	SNMPPort int `json:"snmp_port,omitempty"`
+	HTTPPort int `json:"http_port,omitempty"`
+}
+
+// IsZero reports whether no port is overridden.
+func (p PortOverride) IsZero() bool {
+	return p.SNMPPort == 0 && p.HTTPPort == 0
+}
+
+var (
+	portOverridesMu sync.RWMutex
+	portOverrides   = make(map[string]PortOverride)
+)
+
+// LoadPortOverrides replaces the per-host port overrides, keyed by IP.
+func LoadPortOverrides(overrides map[string]PortOverride) {
+	portOverridesMu.Lock()
+	defer portOverridesMu.Unlock()
+	portOverrides = make(map[string]PortOverride, len(overrides))
+	for host, p := range overrides {
+		if host != "" && !p.IsZero() {
+			portOverrides[host] = p
+		}
+	}
+}
+
+// SetPortOverride sets or, when p is zero, clears the overrides for host. It
+// reports whether anything changed.
+func SetPortOverride(host string, p PortOverride) bool {
+	portOverridesMu.Lock()
+	defer portOverridesMu.Unlock()
+	old, ok := portOverrides[host]
+	if p.IsZero() {
+		delete(portOverrides, host)
+		return ok
+	}
+	portOverrides[host] = p
+	return !ok || old != p
+}
+
+// PortOverrideFor returns the overrides for host, if any.
+func PortOverrideFor(host string) (PortOverride, bool) {
+	portOverridesMu.RLock()
+	defer portOverridesMu.RUnlock()
+	p, ok := portOverrides[host]
+	return p, ok
+}
+
+// PortOverrides returns a copy of all overrides, keyed by host.
+func PortOverrides() map[string]PortOverride {
+	portOverridesMu.RLock()
+	defer portOverridesMu.RUnlock()
+	out := make(map[string]PortOverride, len(portOverrides))
+	for host, p := range portOverrides {
+		out[host] = p
+	}
+	return out
+}
+
+// SNMPPort returns the SNMP port to use for host.
+func SNMPPort(host string) uint16 {
+	if p, ok := PortOverrideFor(host); ok && p.SNMPPort > 0 {
+		return uint16(p.SNMPPort)
+	}
+	return DefaultSNMPPort
+}
+
+// HTTPHost returns host with its overridden HTTP port appended, or host
+// unchanged when there is no override.
+func HTTPHost(host string) string {
+	if p, ok := PortOverrideFor(host); ok && p.HTTPPort > 0 {
+		return net.JoinHostPort(host, strconv.Itoa(p.HTTPPort))
+	}
+	return host
+}
+
+// ParsePort validates a TCP/UDP port number.
+func ParsePort(s string) (int, error) {
+	port, err := strconv.Atoi(strings.TrimSpace(s))
+	if err != nil || port < 1 || port > 65535 {
+		return 0, fmt.Errorf("invalid port %q", s)
+	}
+	return port, nil
+}
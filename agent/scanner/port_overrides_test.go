@@ -0,0 +1,70 @@
+package scanner
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestPortOverrides(t *testing.T) {
+	LoadPortOverrides(map[string]PortOverride{"192.0.2.10": {SNMPPort: 16161, HTTPPort: 16100}})
+	defer LoadPortOverrides(nil)
+
+	if got := SNMPPort("192.0.2.10"); got != 16161 {
+		t.Fatalf("SNMPPort = %d, want 16161", got)
+	}
+	if got := SNMPPort("192.0.2.11"); got != DefaultSNMPPort {
+		t.Fatalf("SNMPPort without override = %d, want %d", got, DefaultSNMPPort)
+	}
+	if got := HTTPHost("192.0.2.10"); got != "192.0.2.10:16100" {
+		t.Fatalf("HTTPHost = %q", got)
+	}
+	if got := HTTPHost("192.0.2.11"); got != "192.0.2.11" {
+		t.Fatalf("HTTPHost without override = %q", got)
+	}
+	if SetPortOverride("192.0.2.10", PortOverride{SNMPPort: 16161, HTTPPort: 16100}) {
+		t.Fatal("expected no change when setting identical override")
+	}
+	if !SetPortOverride("192.0.2.10", PortOverride{}) {
+		t.Fatal("expected zero override to clear entry")
+	}
+	if _, ok := PortOverrideFor("192.0.2.10"); ok {
+		t.Fatal("expected override cleared")
+	}
+}
+
+func TestLivenessPool_ProbesOverriddenHosts(t *testing.T) {
+	LoadPortOverrides(map[string]PortOverride{"192.0.2.20": {HTTPPort: 16100}, "192.0.2.21": {SNMPPort: 16161}})
+	defer LoadPortOverrides(nil)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	jobs := make(chan ScanJob, 3)
+	for _, ip := range []string{"192.0.2.20", "192.0.2.21", "192.0.2.22"} {
+		jobs <- ScanJob{IP: ip}
+	}
+	close(jobs)
+	cfg := ScannerConfig{
+		LivenessWorkers: 1,
+		LivenessPorts:   []int{80},
+		ProbeFunc: func(ip string, ports []int, timeout time.Duration) ([]int, error) {
+			if ports[0] == 16100 {
+				return []int{16100}, nil
+			}
+			return nil, nil
+		},
+	}
+	alive := map[string]LivenessResult{}
+	for res := range StartLivenessPool(ctx, cfg, jobs) {
+		alive[res.Job.IP] = res
+	}
+	if r := alive["192.0.2.20"]; !r.Alive || len(r.OpenPorts) != 1 || r.OpenPorts[0] != 16100 {
+		t.Fatalf("expected forwarded HTTP port probed, got %+v", r)
+	}
+	if !alive["192.0.2.21"].Alive {
+		t.Fatal("expected host with SNMP override treated as alive")
+	}
+	if alive["192.0.2.22"].Alive {
+		t.Fatal("expected host without override and no open ports to be dead")
+	}
+}
@@ -127,7 +127,8 @@ func queryDeviceWithCapabilitiesAndClient(ctx context.Context, ip string, profil
 	if err != nil {
 		return nil, fmt.Errorf("failed to create SNMP client: %w", err)
 	}
-	defer client.Close()
+	// client may be replaced by a credential profile or community sweep below
+	defer func() { client.Close() }()
 
 	// 3. Preliminary vendor detection (fast, minimal GET)
 	var detectedVendor vendor.VendorModule
@@ -140,6 +141,17 @@ func queryDeviceWithCapabilitiesAndClient(ctx context.Context, ip string, profil
 			oids.HrDeviceDescr,
 		}
 		preRes, preErr := client.Get(preOIDs)
+		if preErr != nil || !hasSNMPValues(preRes) {
+			// Try the credential profiles for this device, then the candidate
+			// communities of an authorized onboarding sweep
+			if alt, altRes := tryCredentialProfiles(ctx, cfg, ip, timeoutSeconds, clientFactory, preOIDs); alt != nil {
+				client.Close()
+				client, preRes, preErr = alt, altRes, nil
+			} else if swept, sweptRes := sweepCommunities(ctx, cfg, ip, timeoutSeconds, clientFactory, preOIDs); swept != nil {
+				client.Close()
+				client, preRes, preErr = swept, sweptRes, nil
+			}
+		}
 		if preErr == nil && preRes != nil {
 			for _, pdu := range preRes.Variables {
 				name := strings.TrimPrefix(pdu.Name, ".")
@@ -6,6 +6,8 @@ import (
 	"strings"
 	"time"
 
+	"printmaster/agent/scanscope"
+
 	"github.com/gosnmp/gosnmp"
 )
 
@@ -31,6 +33,10 @@ type SNMPConfig struct {
 	PrivPassword string
 	// ContextName is the SNMPv3 context name (optional)
 	ContextName string
+
+	// ProfileID is set when the config comes from a credential profile.
+	// NewSNMPClient uses such configs unchanged.
+	ProfileID int64
 }
 
 // SNMPClient defines the interface for SNMP operations.
@@ -74,18 +80,9 @@ func GetSNMPConfig() (*SNMPConfig, error) {
 		community = "public"
 	}
 
-	versionStr := strings.ToLower(os.Getenv("SNMP_VERSION"))
-	version := gosnmp.Version2c // default to v2c for better compatibility
-
-	switch versionStr {
-	case "", "2c", "v2c", "2":
-		version = gosnmp.Version2c
-	case "1", "v1":
-		version = gosnmp.Version1
-	case "3", "v3":
-		version = gosnmp.Version3
-	default:
-		return nil, fmt.Errorf("unsupported SNMP version: %s (use 1, 2c, or 3)", versionStr)
+	version, err := ParseSNMPVersion(os.Getenv("SNMP_VERSION"))
+	if err != nil {
+		return nil, err
 	}
 
 	cfg := &SNMPConfig{
@@ -93,66 +90,93 @@ func GetSNMPConfig() (*SNMPConfig, error) {
 		Version:   version,
 	}
 
-	// SNMPv3 configuration from environment
+	// SNMPv3 configuration from environment. Unknown security settings fall
+	// back to no authentication/privacy.
 	if version == gosnmp.Version3 {
 		cfg.Username = os.Getenv("SNMP_USERNAME")
 		cfg.ContextName = os.Getenv("SNMP_CONTEXT_NAME")
-
-		// Security level
-		secLevel := strings.ToLower(os.Getenv("SNMP_SECURITY_LEVEL"))
-		switch secLevel {
-		case "authpriv":
-			cfg.SecurityLevel = gosnmp.AuthPriv
-		case "authnopriv":
-			cfg.SecurityLevel = gosnmp.AuthNoPriv
-		default:
-			cfg.SecurityLevel = gosnmp.NoAuthNoPriv
-		}
-
-		// Auth protocol
-		authProto := strings.ToUpper(os.Getenv("SNMP_AUTH_PROTOCOL"))
-		switch authProto {
-		case "MD5":
-			cfg.AuthProtocol = gosnmp.MD5
-		case "SHA", "SHA1":
-			cfg.AuthProtocol = gosnmp.SHA
-		case "SHA224":
-			cfg.AuthProtocol = gosnmp.SHA224
-		case "SHA256":
-			cfg.AuthProtocol = gosnmp.SHA256
-		case "SHA384":
-			cfg.AuthProtocol = gosnmp.SHA384
-		case "SHA512":
-			cfg.AuthProtocol = gosnmp.SHA512
-		default:
-			cfg.AuthProtocol = gosnmp.NoAuth
-		}
+		cfg.SecurityLevel, _ = ParseSNMPSecurityLevel(os.Getenv("SNMP_SECURITY_LEVEL"))
+		cfg.AuthProtocol, _ = ParseSNMPAuthProtocol(os.Getenv("SNMP_AUTH_PROTOCOL"))
 		cfg.AuthPassword = os.Getenv("SNMP_AUTH_PASSWORD")
-
-		// Privacy protocol
-		privProto := strings.ToUpper(os.Getenv("SNMP_PRIV_PROTOCOL"))
-		switch privProto {
-		case "DES":
-			cfg.PrivProtocol = gosnmp.DES
-		case "AES", "AES128":
-			cfg.PrivProtocol = gosnmp.AES
-		case "AES192":
-			cfg.PrivProtocol = gosnmp.AES192
-		case "AES256":
-			cfg.PrivProtocol = gosnmp.AES256
-		case "AES192C":
-			cfg.PrivProtocol = gosnmp.AES192C
-		case "AES256C":
-			cfg.PrivProtocol = gosnmp.AES256C
-		default:
-			cfg.PrivProtocol = gosnmp.NoPriv
-		}
+		cfg.PrivProtocol, _ = ParseSNMPPrivProtocol(os.Getenv("SNMP_PRIV_PROTOCOL"))
 		cfg.PrivPassword = os.Getenv("SNMP_PRIV_PASSWORD")
 	}
 
 	return cfg, nil
 }
 
+// ParseSNMPVersion parses "1", "2c" or "3" (optionally with a "v" prefix).
+// An empty string selects v2c.
+func ParseSNMPVersion(s string) (gosnmp.SnmpVersion, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "", "2c", "v2c", "2":
+		return gosnmp.Version2c, nil
+	case "1", "v1":
+		return gosnmp.Version1, nil
+	case "3", "v3":
+		return gosnmp.Version3, nil
+	}
+	return gosnmp.Version2c, fmt.Errorf("unsupported SNMP version: %s (use 1, 2c, or 3)", s)
+}
+
+// ParseSNMPSecurityLevel parses noAuthNoPriv, authNoPriv or authPriv
+// (case-insensitive). An empty string selects noAuthNoPriv.
+func ParseSNMPSecurityLevel(s string) (gosnmp.SnmpV3MsgFlags, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "", "noauthnopriv":
+		return gosnmp.NoAuthNoPriv, nil
+	case "authnopriv":
+		return gosnmp.AuthNoPriv, nil
+	case "authpriv":
+		return gosnmp.AuthPriv, nil
+	}
+	return gosnmp.NoAuthNoPriv, fmt.Errorf("unsupported SNMPv3 security level: %s (use noAuthNoPriv, authNoPriv or authPriv)", s)
+}
+
+// ParseSNMPAuthProtocol parses MD5, SHA, SHA224, SHA256, SHA384 or SHA512.
+// An empty string selects no authentication.
+func ParseSNMPAuthProtocol(s string) (gosnmp.SnmpV3AuthProtocol, error) {
+	switch strings.ToUpper(strings.TrimSpace(s)) {
+	case "":
+		return gosnmp.NoAuth, nil
+	case "MD5":
+		return gosnmp.MD5, nil
+	case "SHA", "SHA1":
+		return gosnmp.SHA, nil
+	case "SHA224":
+		return gosnmp.SHA224, nil
+	case "SHA256":
+		return gosnmp.SHA256, nil
+	case "SHA384":
+		return gosnmp.SHA384, nil
+	case "SHA512":
+		return gosnmp.SHA512, nil
+	}
+	return gosnmp.NoAuth, fmt.Errorf("unsupported SNMPv3 auth protocol: %s", s)
+}
+
+// ParseSNMPPrivProtocol parses DES, AES, AES192, AES256, AES192C or AES256C.
+// An empty string selects no privacy.
+func ParseSNMPPrivProtocol(s string) (gosnmp.SnmpV3PrivProtocol, error) {
+	switch strings.ToUpper(strings.TrimSpace(s)) {
+	case "":
+		return gosnmp.NoPriv, nil
+	case "DES":
+		return gosnmp.DES, nil
+	case "AES", "AES128":
+		return gosnmp.AES, nil
+	case "AES192":
+		return gosnmp.AES192, nil
+	case "AES256":
+		return gosnmp.AES256, nil
+	case "AES192C":
+		return gosnmp.AES192C, nil
+	case "AES256C":
+		return gosnmp.AES256C, nil
+	}
+	return gosnmp.NoPriv, fmt.Errorf("unsupported SNMPv3 privacy protocol: %s", s)
+}
+
 // newSNMPClientImpl is the actual implementation of NewSNMPClient.
 func newSNMPClientImpl(cfg *SNMPConfig, target string, timeoutSeconds int) (SNMPClient, error) {
 	if cfg == nil {
@@ -169,7 +193,7 @@ func newSNMPClientImpl(cfg *SNMPConfig, target string, timeoutSeconds int) (SNMP
 
 	conn := &gosnmp.GoSNMP{
 		Target:  target,
-		Port:    161,
+		Port:    SNMPPort(target),
 		Version: cfg.Version,
 		Timeout: time.Duration(timeout) * time.Second,
 		Retries: 3,
@@ -207,7 +231,13 @@ func newSNMPClientImpl(cfg *SNMPConfig, target string, timeoutSeconds int) (SNMP
 var NewSNMPClientFunc = newSNMPClientImpl
 
 // NewSNMPClient creates a new SNMP client for the specified target.
-// If timeoutSeconds is 0, defaults to 30 seconds.
+// If timeoutSeconds is 0, defaults to 30 seconds. The credential profile
+// that last worked for the target, or else a community remembered from a
+// community sweep, replaces the credentials in cfg. Targets
+// outside the authorized scan scope are refused.
 func NewSNMPClient(cfg *SNMPConfig, target string, timeoutSeconds int) (SNMPClient, error) {
-	return NewSNMPClientFunc(cfg, target, timeoutSeconds)
+	if err := scanscope.Check(target, "snmp"); err != nil {
+		return nil, err
+	}
+	return NewSNMPClientFunc(configForTarget(cfg, target), target, timeoutSeconds)
 }
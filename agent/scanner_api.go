@@ -8,6 +8,8 @@ import (
 	"printmaster/agent/agent"
 	"printmaster/agent/scanner"
 	"printmaster/agent/storage"
+
+	"github.com/gosnmp/gosnmp"
 )
 
 // Discover performs discovery using the new modular scanner pipeline.
@@ -59,10 +61,16 @@ func Discover(
 		if err != nil {
 			return nil, err
 		}
+		// Explicit host:port targets apply to every later SNMP/HTTP call
+		if len(agentResult.Ports) > 0 {
+			rememberPortOverrides(agentResult.Ports)
+		}
+		// Targets outside the authorized scan scope are never probed
+		ips := filterScanTargets(agentResult.IPs, "discover")
 		// Convert to scanner.ParseResult
 		scannerResult := &scanner.ParseResult{
-			IPs:        agentResult.IPs,
-			Count:      agentResult.Count,
+			IPs:        ips,
+			Count:      len(ips),
 			Normalized: agentResult.Normalized,
 		}
 		// Convert errors
@@ -96,9 +104,15 @@ func Discover(
 	detectorConfig := scanner.DetectorConfig{
 		SavedDeviceChecker: savedDeviceChecker,
 		SkipSavedDevices:   !discoveryConfig.SNMPEnabled, // Skip if SNMP disabled
+		SNMPDisabled:       !discoveryConfig.SNMPEnabled, // Identify printers over IPP only
 		SNMPTimeout:        timeout,
 	}
 
+	// Pluggable active discovery methods run alongside the pipeline; their
+	// candidates go through live discovery, so the scan just waits for them
+	waitActiveMethods := startActiveDiscoveryMethods(ctx, ranges)
+	defer waitActiveMethods()
+
 	// Step 4: Choose mode
 	switch mode {
 	case "quick":
@@ -160,7 +174,7 @@ func quickDiscovery(
 
 	// Parse each range
 	for _, rangeText := range ranges {
-		scannerResult, err := parseAdapter(rangeText, 10000)
+		scannerResult, err := parseAdapter(rangeText, discoveryMaxAddresses)
 		if err != nil {
 			appLogger.Warn("Failed to parse range", "range", rangeText, "error", err)
 			continue
@@ -178,7 +192,7 @@ func quickDiscovery(
 	scannerConfig := scanner.ScannerConfig{
 		LivenessWorkers:  concurrency,
 		LivenessTimeout:  500 * time.Millisecond,
-		LivenessPorts:    []int{9100, 80, 443},
+		LivenessPorts:    []int{9100, 80, 443, 631},
 		DetectionWorkers: 10,
 		DetectFunc:       scanner.DetectFunc(detectorConfig),
 	}
@@ -203,6 +217,14 @@ func quickDiscovery(
 			continue
 		}
 
+		// Printers without SNMP were identified over IPP
+		if ipp, ok := dr.Info.(*scanner.IPPResult); ok {
+			pi := agent.PrinterInfoFromIPP(ipp, &agent.ScanMeta{OpenPorts: []int{scanner.DefaultIPPPort}})
+			pi.DiscoveryMethods = append(pi.DiscoveryMethods, "quick-discovery")
+			results = append(results, pi)
+			continue
+		}
+
 		// Extract basic info from QueryResult
 		if qr, ok := dr.Info.(*scanner.QueryResult); ok {
 			// Parse PDUs to get printer info
@@ -258,7 +280,7 @@ func fullDiscovery(
 
 	// Parse each range
 	for _, rangeText := range ranges {
-		scannerResult, err := parseAdapter(rangeText, 10000)
+		scannerResult, err := parseAdapter(rangeText, discoveryMaxAddresses)
 		if err != nil {
 			appLogger.Warn("Failed to parse range", "range", rangeText, "error", err)
 			continue
@@ -353,6 +375,15 @@ func fullDiscovery(
 			continue
 		}
 
+		// Printers without SNMP were identified over IPP
+		if ipp, ok := rawResult.(*scanner.IPPResult); ok {
+			pi := agent.PrinterInfoFromIPP(ipp, &agent.ScanMeta{OpenPorts: []int{scanner.DefaultIPPPort}})
+			pi.DiscoveryMethods = append(pi.DiscoveryMethods, "full-discovery")
+			results = append(results, pi)
+			agent.UpsertDiscoveredPrinter(pi)
+			continue
+		}
+
 		// Convert QueryResult to PrinterInfo
 		if qr, ok := rawResult.(*scanner.QueryResult); ok {
 			pi, isPrinter := agent.ParsePDUs(qr.IP, qr.PDUs, nil, nil)
@@ -456,12 +487,16 @@ func LiveDiscoveryDetect(ctx context.Context, ip string, timeoutSeconds int) (*a
 		"", // vendor auto-detected
 		timeoutSeconds,
 	)
-	if err != nil {
-		return nil, fmt.Errorf("query failed for %s: %w", ip, err)
-	}
-
-	// Check if we got any data
-	if result == nil || len(result.PDUs) == 0 {
+	if err != nil || result == nil || len(result.PDUs) == 0 {
+		// Printers announced over mDNS often have SNMP turned off; IPP
+		// still identifies them
+		if ipp, ippErr := scanner.QueryIPP(ctx, ip, time.Duration(timeoutSeconds)*time.Second); ippErr == nil && ipp.MakeAndModel != "" {
+			pi := agent.PrinterInfoFromIPP(ipp, &agent.ScanMeta{OpenPorts: []int{scanner.DefaultIPPPort}})
+			return &pi, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("query failed for %s: %w", ip, err)
+		}
 		return nil, fmt.Errorf("no SNMP data received from %s", ip)
 	}
 
@@ -559,6 +594,13 @@ func CollectMetrics(ctx context.Context, ip string, serial string, vendorHint st
 
 // CollectMetricsWithOIDs collects metrics from a device, optionally using learned OIDs for efficiency
 func CollectMetricsWithOIDs(ctx context.Context, ip string, serial string, vendorHint string, timeoutSeconds int, learnedOIDs *agent.LearnedOIDMap) (*agent.DeviceMetricsSnapshot, error) {
+	snapshot, _, err := collectMetricsWithPDUs(ctx, ip, serial, vendorHint, timeoutSeconds, learnedOIDs)
+	return snapshot, err
+}
+
+// collectMetricsWithPDUs collects metrics like CollectMetricsWithOIDs and also
+// returns the raw PDUs the counters were parsed from (used by meter reads).
+func collectMetricsWithPDUs(ctx context.Context, ip string, serial string, vendorHint string, timeoutSeconds int, learnedOIDs *agent.LearnedOIDMap) (*agent.DeviceMetricsSnapshot, []gosnmp.SnmpPDU, error) {
 	if timeoutSeconds <= 0 {
 		timeoutSeconds = 5
 	}
@@ -609,12 +651,12 @@ func CollectMetricsWithOIDs(ctx context.Context, ip string, serial string, vendo
 		// Query specific learned OIDs directly using SNMP GET
 		cfg, err := scanner.GetSNMPConfig()
 		if err != nil {
-			return nil, fmt.Errorf("failed to get SNMP config: %w", err)
+			return nil, nil, fmt.Errorf("failed to get SNMP config: %w", err)
 		}
 
 		client, err := scanner.NewSNMPClient(cfg, ip, timeoutSeconds)
 		if err != nil {
-			return nil, fmt.Errorf("failed to create SNMP client: %w", err)
+			return nil, nil, fmt.Errorf("failed to create SNMP client: %w", err)
 		}
 		defer client.Close()
 
@@ -642,13 +684,13 @@ func CollectMetricsWithOIDs(ctx context.Context, ip string, serial string, vendo
 			timeoutSeconds,
 		)
 		if err != nil {
-			return nil, fmt.Errorf("metrics query failed for %s: %w", ip, err)
+			return nil, nil, fmt.Errorf("metrics query failed for %s: %w", ip, err)
 		}
 	}
 
 	// Check if we got any data
 	if result == nil || len(result.PDUs) == 0 {
-		return nil, fmt.Errorf("no SNMP metrics data received from %s", ip)
+		return nil, nil, fmt.Errorf("no SNMP metrics data received from %s", ip)
 	}
 
 	appLogger.Info("Metrics SNMP query complete", "ip", ip, "vendor", vendorHint, "pdus_received", len(result.PDUs))
@@ -673,6 +715,9 @@ func CollectMetricsWithOIDs(ctx context.Context, ip string, serial string, vendo
 	})
 	// Merge vendor-specific metrics (ICE-style OIDs)
 	agent.MergeVendorMetrics(&pi, result.PDUs, vendorHint)
+	if useLearnedOIDs {
+		agent.MergeLearnedMeters(&pi, learnedOIDs, result.PDUs)
+	}
 
 	// Copy capabilities from QueryResult if present
 	if result.Capabilities != nil {
@@ -736,28 +781,48 @@ func CollectMetricsWithOIDs(ctx context.Context, ip string, serial string, vendo
 		}
 	}
 
-	// Extract toner levels - only record expected levels based on device type
-	// Use >= 0 since 0 is a valid level (empty toner)
-	// For mono printers, only record black toner (ignore color OIDs)
+	// Duplex and N-up counters come in different units per vendor
+	sheets := agent.NormalizeSheetCounters(pi.Meters, result.PDUs, snapshot.PageCount)
+	snapshot.DuplexSheets = sheets.DuplexSheets
+	snapshot.SimplexSheets = sheets.SimplexSheets
+	snapshot.NUpSheets = sheets.NUpSheets
+	if rate, ok := sheets.DuplexRate(); ok {
+		appLogger.Debug("Sheet counters normalized", "ip", ip,
+			"duplex_sheets", sheets.DuplexSheets, "simplex_sheets", sheets.SimplexSheets,
+			"nup_sheets", sheets.NUpSheets, "duplex_rate", rate)
+	}
+
+	snapshot.TonerLevels = tonerLevelsFromPrinterInfo(pi)
+	if len(pi.SupplyReadings) > 0 {
+		snapshot.SupplyReadings = pi.SupplyReadings
+	}
+
+	return snapshot, result.PDUs, nil
+}
+
+// tonerLevelsFromPrinterInfo returns the toner levels expected for the
+// device type. 0 is a valid level (empty toner); mono printers only report
+// black, even if color OIDs answered.
+func tonerLevelsFromPrinterInfo(pi agent.PrinterInfo) map[string]interface{} {
+	levels := make(map[string]interface{})
 	isMono := pi.IsMono || (!pi.IsColor && pi.TonerLevelBlack > 0 &&
 		pi.TonerLevelCyan == 0 && pi.TonerLevelMagenta == 0 && pi.TonerLevelYellow == 0)
 
 	if pi.TonerLevelBlack >= 0 && (pi.TonerDescBlack != "" || pi.TonerLevelBlack > 0) {
-		snapshot.TonerLevels["black"] = pi.TonerLevelBlack
+		levels["black"] = pi.TonerLevelBlack
 	}
 
 	// Only record color toner for color devices
 	if !isMono {
 		if pi.TonerLevelCyan >= 0 && (pi.TonerDescCyan != "" || pi.TonerLevelCyan > 0) {
-			snapshot.TonerLevels["cyan"] = pi.TonerLevelCyan
+			levels["cyan"] = pi.TonerLevelCyan
 		}
 		if pi.TonerLevelMagenta >= 0 && (pi.TonerDescMagenta != "" || pi.TonerLevelMagenta > 0) {
-			snapshot.TonerLevels["magenta"] = pi.TonerLevelMagenta
+			levels["magenta"] = pi.TonerLevelMagenta
 		}
 		if pi.TonerLevelYellow >= 0 && (pi.TonerDescYellow != "" || pi.TonerLevelYellow > 0) {
-			snapshot.TonerLevels["yellow"] = pi.TonerLevelYellow
+			levels["yellow"] = pi.TonerLevelYellow
 		}
 	}
-
-	return snapshot, nil
+	return levels
 }
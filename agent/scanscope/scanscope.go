@@ -0,0 +1,161 @@
+// Package scanscope enforces the authorized scan scope: the CIDRs an agent is
+// allowed to send discovery and SNMP traffic to. MSPs set it from the server
+// so an agent on a shared or misconfigured network never probes a customer
+// network it was not hired to manage.
+//
+// The active scope is process-wide so every SNMP client factory and scan
+// entry point checks the same policy.
+package scanscope
+
+import (
+	"errors"
+	"net"
+	"net/netip"
+	"strings"
+	"sync/atomic"
+
+	pmsettings "printmaster/common/settings"
+)
+
+// ErrOutOfScope is returned (wrapped) for targets outside the active scope.
+var ErrOutOfScope = errors.New("target outside the authorized scan scope")
+
+// Scope is a set of authorized prefixes. A nil or empty Scope allows every
+// target.
+type Scope struct {
+	Prefixes []netip.Prefix
+	// denyAll is set when the configured text had entries but none were
+	// valid, so a typo never lifts the restriction.
+	denyAll bool
+}
+
+// New builds a Scope from the authorized_scan_scope setting text. Invalid
+// entries are returned and otherwise ignored; when every entry is invalid the
+// scope refuses all targets.
+func New(text string) (*Scope, []string) {
+	prefixes, invalid := pmsettings.ScanScopePrefixes(text)
+	return &Scope{Prefixes: prefixes, denyAll: len(prefixes) == 0 && len(invalid) > 0}, invalid
+}
+
+// Empty reports whether the scope has no prefixes (scanning is unrestricted).
+func (s *Scope) Empty() bool {
+	return s == nil || (len(s.Prefixes) == 0 && !s.denyAll)
+}
+
+// Contains reports whether target (an IP, optionally with a port) is inside
+// the scope. When the scope is not empty, host names are refused because
+// they cannot be checked without resolving them.
+func (s *Scope) Contains(target string) bool {
+	if s.Empty() {
+		return true
+	}
+	addr, ok := parseTarget(target)
+	if !ok {
+		return false
+	}
+	for _, p := range s.Prefixes {
+		if p.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// Filter splits targets into those inside and outside the scope.
+func (s *Scope) Filter(targets []string) (allowed, refused []string) {
+	if s.Empty() {
+		return targets, nil
+	}
+	allowed = make([]string, 0, len(targets))
+	for _, t := range targets {
+		if s.Contains(t) {
+			allowed = append(allowed, t)
+		} else {
+			refused = append(refused, t)
+		}
+	}
+	return allowed, refused
+}
+
+// String returns the prefixes as a comma separated list.
+func (s *Scope) String() string {
+	if s.Empty() {
+		return ""
+	}
+	if len(s.Prefixes) == 0 {
+		return "none"
+	}
+	parts := make([]string, len(s.Prefixes))
+	for i, p := range s.Prefixes {
+		parts[i] = p.String()
+	}
+	return strings.Join(parts, ", ")
+}
+
+func parseTarget(target string) (netip.Addr, bool) {
+	target = strings.TrimSpace(target)
+	if addr, err := netip.ParseAddr(target); err == nil {
+		return addr.Unmap(), true
+	}
+	if host, _, err := net.SplitHostPort(target); err == nil {
+		if addr, err := netip.ParseAddr(host); err == nil {
+			return addr.Unmap(), true
+		}
+	}
+	return netip.Addr{}, false
+}
+
+var (
+	active   atomic.Pointer[Scope]
+	observer atomic.Pointer[func(target, source string)]
+)
+
+// SetActive replaces the enforced scope. nil or an empty scope lifts the
+// restriction.
+func SetActive(s *Scope) {
+	if s.Empty() {
+		s = nil
+	}
+	active.Store(s)
+}
+
+// Active returns the enforced scope, or nil when scanning is unrestricted.
+func Active() *Scope {
+	return active.Load()
+}
+
+// SetRefusalObserver registers fn to be told about every target Check
+// refuses; the agent uses it to log refusals.
+func SetRefusalObserver(fn func(target, source string)) {
+	if fn == nil {
+		observer.Store(nil)
+		return
+	}
+	observer.Store(&fn)
+}
+
+// Check returns an error wrapping ErrOutOfScope when target is outside the
+// active scope. source names the caller (e.g. "snmp", "live:mdns") for the
+// refusal observer.
+func Check(target, source string) error {
+	if Active().Contains(target) {
+		return nil
+	}
+	if fn := observer.Load(); fn != nil {
+		(*fn)(target, source)
+	}
+	return &OutOfScopeError{Target: target}
+}
+
+// OutOfScopeError reports a refused target.
+type OutOfScopeError struct {
+	Target string
+}
+
+func (e *OutOfScopeError) Error() string {
+	return e.Target + ": " + ErrOutOfScope.Error()
+}
+
+func (e *OutOfScopeError) Unwrap() error {
+	return ErrOutOfScope
+}
@@ -0,0 +1,81 @@
+package scanscope
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestScopeContains(t *testing.T) {
+	s, invalid := New("10.1.0.0/16\n192.168.5.7 # printer room\nbogus")
+	if len(invalid) != 1 || invalid[0] != "bogus" {
+		t.Fatalf("invalid = %q", invalid)
+	}
+
+	tests := []struct {
+		target string
+		want   bool
+	}{
+		{"10.1.2.3", true},
+		{"10.2.0.1", false},
+		{"192.168.5.7", true},
+		{"192.168.5.8", false},
+		{"10.1.9.9:1161", true},
+		{"::ffff:10.1.0.5", true},
+		{"printer.local", false},
+		{"", false},
+	}
+	for _, tt := range tests {
+		if got := s.Contains(tt.target); got != tt.want {
+			t.Errorf("Contains(%q) = %v, want %v", tt.target, got, tt.want)
+		}
+	}
+
+	if typo, _ := New("10.0.0.0/33"); typo.Empty() || typo.Contains("10.0.0.1") {
+		t.Error("scope with only invalid entries should refuse every target")
+	}
+
+	var unrestricted *Scope
+	if !unrestricted.Contains("8.8.8.8") || !(&Scope{}).Contains("printer.local") {
+		t.Error("empty scope should allow every target")
+	}
+}
+
+func TestScopeFilter(t *testing.T) {
+	s, _ := New("10.0.0.0/30")
+	allowed, refused := s.Filter([]string{"10.0.0.1", "10.0.0.5", "10.0.0.2"})
+	if len(allowed) != 2 || len(refused) != 1 || refused[0] != "10.0.0.5" {
+		t.Fatalf("allowed=%v refused=%v", allowed, refused)
+	}
+}
+
+func TestCheckUsesActiveScope(t *testing.T) {
+	t.Cleanup(func() {
+		SetActive(nil)
+		SetRefusalObserver(nil)
+	})
+
+	var refusedTarget, refusedSource string
+	SetRefusalObserver(func(target, source string) { refusedTarget, refusedSource = target, source })
+
+	if err := Check("203.0.113.9", "snmp"); err != nil {
+		t.Fatalf("no active scope: unexpected error %v", err)
+	}
+
+	s, _ := New("10.0.0.0/8")
+	SetActive(s)
+	if err := Check("10.9.9.9", "snmp"); err != nil {
+		t.Fatalf("in scope: unexpected error %v", err)
+	}
+	err := Check("203.0.113.9", "live:mdns")
+	if !errors.Is(err, ErrOutOfScope) {
+		t.Fatalf("out of scope: err = %v", err)
+	}
+	if refusedTarget != "203.0.113.9" || refusedSource != "live:mdns" {
+		t.Errorf("observer got %q %q", refusedTarget, refusedSource)
+	}
+
+	SetActive(&Scope{})
+	if Active() != nil {
+		t.Error("empty scope should clear the restriction")
+	}
+}
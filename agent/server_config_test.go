@@ -4,6 +4,8 @@ import (
 	"encoding/json"
 	"fmt"
 	"testing"
+
+	"printmaster/agent/storage"
 )
 
 type fakeAgentConfigStore struct {
@@ -39,7 +41,10 @@ func (f *fakeAgentConfigStore) GetConfigValue(key string, dest interface{}) erro
 	}
 	return json.Unmarshal(raw, dest)
 }
-func (f *fakeAgentConfigStore) Close() error { return nil }
+func (f *fakeAgentConfigStore) ListSNMPProfiles() ([]storage.SNMPProfile, error) { return nil, nil }
+func (f *fakeAgentConfigStore) SaveSNMPProfile(*storage.SNMPProfile) error       { return nil }
+func (f *fakeAgentConfigStore) DeleteSNMPProfile(int64) error                    { return nil }
+func (f *fakeAgentConfigStore) Close() error                                     { return nil }
 
 func TestApplyServerConfigFromStoreMergesPersistedSettings(t *testing.T) {
 	store := newFakeAgentConfigStore()
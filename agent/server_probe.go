@@ -10,6 +10,9 @@ import (
 	"net/url"
 	"strings"
 	"time"
+
+	"printmaster/agent/agent"
+	"printmaster/agent/netproxy"
 )
 
 // ServerProbeResult captures the outcome of probing a server URL prior to joining it.
@@ -17,6 +20,7 @@ type ServerProbeResult struct {
 	ServerURL string          `json:"server_url"`
 	Scheme    string          `json:"scheme"`
 	Reachable bool            `json:"reachable"`
+	Proxy     string          `json:"proxy,omitempty"` // Outbound proxy used (password redacted)
 	TLS       TLSProbeSummary `json:"tls"`
 }
 
@@ -68,22 +72,32 @@ func probeServer(ctx context.Context, rawURL string) (*ServerProbeResult, error)
 		dialer.Deadline = deadline
 	}
 
+	proxyURL, err := agent.OutboundProxy().ProxyFor(ctx, normalized)
+	if err != nil {
+		return nil, err
+	}
+	if proxyURL != nil {
+		result.Proxy = proxyURL.Redacted()
+	}
+
+	rawConn, err := netproxy.DialContext(ctx, dialer, proxyURL, hostPort)
+	if err != nil {
+		result.TLS.Error = err.Error()
+		result.TLS.ErrorCode = "unreachable"
+		return result, nil
+	}
 	if !result.TLS.Enabled {
-		conn, err := dialer.DialContext(ctx, "tcp", hostPort)
-		if err != nil {
-			result.TLS.Error = err.Error()
-			result.TLS.ErrorCode = "unreachable"
-			return result, nil
-		}
-		_ = conn.Close()
+		_ = rawConn.Close()
 		result.Reachable = true
 		return result, nil
 	}
 
 	serverName := normalized.Hostname()
 	tlsCfg := &tls.Config{ServerName: serverName}
-	conn, err := tls.DialWithDialer(dialer, "tcp", hostPort, tlsCfg)
-	if err != nil {
+	_ = rawConn.SetDeadline(time.Now().Add(dialTimeout))
+	conn := tls.Client(rawConn, tlsCfg)
+	if err := conn.HandshakeContext(ctx); err != nil {
+		_ = conn.Close()
 		result.TLS.Error = err.Error()
 		result.TLS.ErrorCode = classifyTLSError(err)
 		return result, nil
@@ -40,16 +40,31 @@ func (p *program) run() {
 		p.svcLogger.Info("PrintMaster Agent service running")
 	}
 
-	// Call runInteractive with context for graceful shutdown
-	// Service mode doesn't provide a config path, pass empty string so
-	// runInteractive will fall back to default config discovery.
-	runInteractive(p.ctx, "")
+	// In child-process mode the service only supervises a worker process,
+	// restarting it whenever it exits or hangs
+	if wd := loadServiceWatchdogConfig(); wd.Enabled && wd.ChildProcess {
+		if err := runWatchdogSupervisor(p.ctx, wd, p.logf); err != nil && p.svcLogger != nil {
+			p.svcLogger.Errorf("PrintMaster Agent watchdog supervisor failed: %v", err)
+		}
+	} else {
+		// Call runInteractive with context for graceful shutdown
+		// Service mode doesn't provide a config path, pass empty string so
+		// runInteractive will fall back to default config discovery.
+		runInteractive(p.ctx, "")
+	}
 
 	if p.svcLogger != nil {
 		p.svcLogger.Info("PrintMaster Agent service stopping")
 	}
 }
 
+// logf writes supervisor messages to the service log.
+func (p *program) logf(format string, args ...interface{}) {
+	if p.svcLogger != nil {
+		p.svcLogger.Infof(format, args...)
+	}
+}
+
 func (p *program) Stop(s service.Service) error {
 	// Service is stopping, cancel context and wait for shutdown
 	if p.svcLogger != nil {
@@ -0,0 +1,192 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"printmaster/agent/agent"
+	"printmaster/agent/scanner"
+	"printmaster/agent/scanscope"
+	"printmaster/agent/servicescan"
+	"printmaster/agent/storage"
+
+	"github.com/gosnmp/gosnmp"
+)
+
+var (
+	serviceScanEnabled atomic.Bool
+	serviceScanMu      sync.Mutex
+	serviceScanQueue   *servicescan.Queue
+	// serviceScanLimits holds the configured rate limit and rescan interval so
+	// they can be applied when the queue starts after settings are loaded.
+	serviceScanLimits = struct {
+		maxPerMinute int
+		rescanAfter  time.Duration
+	}{maxPerMinute: 6, rescanAfter: 24 * time.Hour}
+)
+
+// startServiceScanner creates the service scan queue and its worker. The
+// worker idles until devices are enqueued, which only happens while the
+// service scan setting is enabled.
+func startServiceScanner(ctx context.Context) {
+	serviceScanMu.Lock()
+	defer serviceScanMu.Unlock()
+	if serviceScanQueue != nil {
+		return
+	}
+	scanner := servicescan.NewScanner(probeSNMPVersion)
+	serviceScanQueue = servicescan.NewQueue(scanner, serviceScanLimits.maxPerMinute, serviceScanLimits.rescanAfter, storeServiceScanResult)
+	go serviceScanQueue.Run(ctx)
+}
+
+// applyServiceScanSettings updates the service scan opt-in and limits from a
+// discovery settings map. Missing keys leave the current values untouched.
+func applyServiceScanSettings(disc map[string]interface{}) {
+	if v, ok := disc["service_scan_enabled"].(bool); ok {
+		if serviceScanEnabled.Swap(v) != v && appLogger != nil {
+			appLogger.Info("Service scan setting changed", "enabled", v)
+		}
+	}
+	serviceScanMu.Lock()
+	defer serviceScanMu.Unlock()
+	if v, ok := disc["service_scan_max_per_minute"].(float64); ok && v > 0 {
+		serviceScanLimits.maxPerMinute = int(v)
+	}
+	if v, ok := disc["service_scan_interval_hours"].(float64); ok && v > 0 {
+		serviceScanLimits.rescanAfter = time.Duration(v) * time.Hour
+	}
+	if serviceScanQueue != nil {
+		serviceScanQueue.Configure(serviceScanLimits.maxPerMinute, serviceScanLimits.rescanAfter)
+	}
+}
+
+// enqueueServiceScan schedules a service scan of a freshly persisted device
+// when the tenant has opted in.
+func enqueueServiceScan(device *storage.Device) {
+	if device == nil || !serviceScanEnabled.Load() {
+		return
+	}
+	if scanscope.Check(device.IP, "service_scan") != nil {
+		return
+	}
+	serviceScanMu.Lock()
+	q := serviceScanQueue
+	serviceScanMu.Unlock()
+	if q == nil {
+		return
+	}
+	q.Enqueue(device.Serial, device.IP, serviceScanTime(device.RawData))
+}
+
+// preserveServiceScan carries the previous service scan result over to a
+// device rebuilt from discovery data, which would otherwise drop it.
+func preserveServiceScan(device, existing *storage.Device) {
+	if device == nil || existing == nil || existing.RawData == nil {
+		return
+	}
+	prev, ok := existing.RawData[servicescan.RawDataKey]
+	if !ok {
+		return
+	}
+	if device.RawData == nil {
+		device.RawData = map[string]interface{}{}
+	}
+	if _, has := device.RawData[servicescan.RawDataKey]; !has {
+		device.RawData[servicescan.RawDataKey] = prev
+	}
+}
+
+// serviceScanTime returns when the stored service scan ran (zero if never).
+func serviceScanTime(raw map[string]interface{}) time.Time {
+	switch v := raw[servicescan.RawDataKey].(type) {
+	case servicescan.Result:
+		return v.ScannedAt
+	case *servicescan.Result:
+		return v.ScannedAt
+	case map[string]interface{}:
+		if s, ok := v["scanned_at"].(string); ok {
+			if t, err := time.Parse(time.RFC3339Nano, s); err == nil {
+				return t
+			}
+		}
+	}
+	return time.Time{}
+}
+
+// storeServiceScanResult saves a completed scan in the device's RawData.
+func storeServiceScanResult(ctx context.Context, serial string, res servicescan.Result) {
+	if deviceStore == nil {
+		return
+	}
+	device, err := deviceStore.Get(ctx, serial)
+	if err != nil {
+		if appLogger != nil {
+			appLogger.Debug("Service scan result for unknown device", "serial", serial, "error", err)
+		}
+		return
+	}
+	if device.RawData == nil {
+		device.RawData = map[string]interface{}{}
+	}
+	device.RawData[servicescan.RawDataKey] = res
+	if err := deviceStore.Update(ctx, device); err != nil {
+		if appLogger != nil {
+			appLogger.Warn("Failed to store service scan result", "serial", serial, "error", err)
+		}
+		return
+	}
+	if appLogger != nil {
+		appLogger.Debug("Service scan completed", "serial", serial, "ip", res.IP,
+			"open_ports", res.OpenPorts, "snmp_versions", res.SNMPVersions)
+	}
+}
+
+// probeSNMPVersion checks whether ip answers a sysObjectID GET using the given
+// SNMP version. v1/v2c use the configured community; v3 only checks that the
+// device answers USM engine discovery.
+func probeSNMPVersion(ctx context.Context, ip, version string) bool {
+	cfg, err := agent.GetSNMPConfig()
+	if err != nil {
+		return false
+	}
+	client := &gosnmp.GoSNMP{
+		Target:    ip,
+		Port:      scanner.SNMPPort(ip),
+		Timeout:   2 * time.Second,
+		Retries:   0,
+		Context:   ctx,
+		Community: cfg.Community,
+	}
+	switch version {
+	case "1":
+		client.Version = gosnmp.Version1
+	case "2c":
+		client.Version = gosnmp.Version2c
+	case "3":
+		client.Version = gosnmp.Version3
+		client.SecurityModel = gosnmp.UserSecurityModel
+		client.MsgFlags = gosnmp.NoAuthNoPriv
+		client.SecurityParameters = &gosnmp.UsmSecurityParameters{UserName: cfg.Username}
+	default:
+		return false
+	}
+	if err := client.Connect(); err != nil {
+		return false
+	}
+	defer client.Conn.Close()
+
+	pkt, err := client.Get([]string{"1.3.6.1.2.1.1.2.0"})
+	if version == "3" {
+		// Any reply other than a timeout (including unknown-user reports)
+		// proves the v3 engine is listening.
+		if err == nil {
+			return true
+		}
+		msg := strings.ToLower(err.Error())
+		return ctx.Err() == nil && !strings.Contains(msg, "timeout") && !strings.Contains(msg, "refused")
+	}
+	return err == nil && pkt != nil && pkt.Error == gosnmp.NoError && len(pkt.Variables) > 0
+}
@@ -0,0 +1,208 @@
+// Package servicescan performs a small, rate-limited service scan of
+// discovered printers: a fixed set of TCP ports plus the SNMP versions the
+// device accepts. The results feed security posture reporting (open Telnet or
+// FTP, SNMPv1 enabled) and are deliberately conservative so the scan is safe
+// to run against fragile embedded network stacks.
+package servicescan
+
+import (
+	"context"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// DefaultPorts is the limited port set probed on every device.
+var DefaultPorts = []int{21, 23, 80, 443, 515, 631, 9100}
+
+// portServices names the services expected on DefaultPorts.
+var portServices = map[int]string{
+	21:   "ftp",
+	23:   "telnet",
+	80:   "http",
+	443:  "https",
+	515:  "lpd",
+	631:  "ipp",
+	9100: "raw",
+}
+
+// SNMPVersions are the versions checked by the SNMP probe.
+var SNMPVersions = []string{"1", "2c", "3"}
+
+// Result is the outcome of scanning one device. It is stored in the device's
+// RawData under RawDataKey.
+type Result struct {
+	ScannedAt    time.Time `json:"scanned_at"`
+	IP           string    `json:"ip"`
+	OpenPorts    []int     `json:"open_ports"`
+	Services     []string  `json:"services,omitempty"`
+	SNMPVersions []string  `json:"snmp_versions,omitempty"` // SNMP versions the device answered
+}
+
+// RawDataKey is the device RawData key holding the latest Result.
+const RawDataKey = "service_scan"
+
+// SNMPProbeFunc reports whether ip answers SNMP requests of the given version
+// ("1", "2c" or "3").
+type SNMPProbeFunc func(ctx context.Context, ip, version string) bool
+
+// Scanner probes a single device.
+type Scanner struct {
+	Ports       []int
+	DialTimeout time.Duration
+	// PortDelay spaces out connection attempts to the same device.
+	PortDelay time.Duration
+	// SNMPProbe checks accepted SNMP versions; nil skips the SNMP check.
+	SNMPProbe SNMPProbeFunc
+}
+
+// NewScanner returns a Scanner for DefaultPorts with conservative timings.
+func NewScanner(snmpProbe SNMPProbeFunc) *Scanner {
+	return &Scanner{
+		Ports:       DefaultPorts,
+		DialTimeout: 2 * time.Second,
+		PortDelay:   100 * time.Millisecond,
+		SNMPProbe:   snmpProbe,
+	}
+}
+
+// Scan probes ip sequentially. It never runs more than one connection to the
+// device at a time.
+func (s *Scanner) Scan(ctx context.Context, ip string) Result {
+	res := Result{ScannedAt: time.Now().UTC(), IP: ip, OpenPorts: []int{}}
+	dialer := &net.Dialer{Timeout: s.DialTimeout}
+	for i, port := range s.Ports {
+		if i > 0 && s.PortDelay > 0 {
+			select {
+			case <-ctx.Done():
+				return res
+			case <-time.After(s.PortDelay):
+			}
+		}
+		conn, err := dialer.DialContext(ctx, "tcp", net.JoinHostPort(ip, strconv.Itoa(port)))
+		if err != nil {
+			continue
+		}
+		conn.Close()
+		res.OpenPorts = append(res.OpenPorts, port)
+		if name, ok := portServices[port]; ok {
+			res.Services = append(res.Services, name)
+		}
+	}
+	if s.SNMPProbe != nil {
+		for _, v := range SNMPVersions {
+			if ctx.Err() != nil {
+				break
+			}
+			if s.SNMPProbe(ctx, ip, v) {
+				res.SNMPVersions = append(res.SNMPVersions, v)
+			}
+		}
+	}
+	return res
+}
+
+// ResultFunc receives completed scans.
+type ResultFunc func(ctx context.Context, serial string, res Result)
+
+type job struct {
+	serial string
+	ip     string
+}
+
+// Queue rate-limits scans across all devices. Devices scanned within the
+// rescan interval, already queued, or arriving while the queue is full are
+// dropped rather than delayed.
+type Queue struct {
+	scanner  *Scanner
+	onResult ResultFunc
+	jobs     chan job
+
+	mu          sync.Mutex
+	spacing     time.Duration
+	rescanAfter time.Duration
+	lastScan    map[string]time.Time
+	pending     map[string]bool
+}
+
+// NewQueue creates a queue that scans at most maxPerMinute devices per minute
+// and skips devices scanned within rescanAfter.
+func NewQueue(scanner *Scanner, maxPerMinute int, rescanAfter time.Duration, onResult ResultFunc) *Queue {
+	q := &Queue{
+		scanner:  scanner,
+		onResult: onResult,
+		jobs:     make(chan job, 256),
+		lastScan: make(map[string]time.Time),
+		pending:  make(map[string]bool),
+	}
+	q.Configure(maxPerMinute, rescanAfter)
+	return q
+}
+
+// Configure updates the rate limit and rescan interval.
+func (q *Queue) Configure(maxPerMinute int, rescanAfter time.Duration) {
+	if maxPerMinute < 1 {
+		maxPerMinute = 1
+	}
+	q.mu.Lock()
+	q.spacing = time.Minute / time.Duration(maxPerMinute)
+	q.rescanAfter = rescanAfter
+	q.mu.Unlock()
+}
+
+// Enqueue schedules a scan of the device. lastScanned seeds the rescan check
+// from persisted results (zero if unknown). It reports whether the device was
+// queued.
+func (q *Queue) Enqueue(serial, ip string, lastScanned time.Time) bool {
+	if serial == "" || ip == "" {
+		return false
+	}
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.pending[serial] {
+		return false
+	}
+	if last := q.lastScan[serial]; last.After(lastScanned) {
+		lastScanned = last
+	}
+	if !lastScanned.IsZero() && time.Since(lastScanned) < q.rescanAfter {
+		return false
+	}
+	select {
+	case q.jobs <- job{serial: serial, ip: ip}:
+		q.pending[serial] = true
+		return true
+	default:
+		return false
+	}
+}
+
+// Run processes queued scans until ctx is cancelled, waiting the configured
+// spacing between devices.
+func (q *Queue) Run(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case j := <-q.jobs:
+			res := q.scanner.Scan(ctx, j.ip)
+			q.mu.Lock()
+			delete(q.pending, j.serial)
+			q.lastScan[j.serial] = res.ScannedAt
+			spacing := q.spacing
+			q.mu.Unlock()
+			if ctx.Err() != nil {
+				return
+			}
+			if q.onResult != nil {
+				q.onResult(ctx, j.serial, res)
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(spacing):
+			}
+		}
+	}
+}
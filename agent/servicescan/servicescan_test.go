@@ -0,0 +1,80 @@
+package servicescan
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestScannerFindsOpenPortAndSNMPVersions(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	defer l.Close()
+	open := l.Addr().(*net.TCPAddr).Port
+
+	s := NewScanner(func(ctx context.Context, ip, version string) bool {
+		return version == "1" || version == "2c"
+	})
+	s.Ports = []int{open, open + 1}
+	s.DialTimeout = 500 * time.Millisecond
+	s.PortDelay = 0
+
+	res := s.Scan(context.Background(), "127.0.0.1")
+	if len(res.OpenPorts) != 1 || res.OpenPorts[0] != open {
+		t.Fatalf("OpenPorts = %v, want [%d]", res.OpenPorts, open)
+	}
+	if len(res.SNMPVersions) != 2 || res.SNMPVersions[0] != "1" || res.SNMPVersions[1] != "2c" {
+		t.Fatalf("SNMPVersions = %v", res.SNMPVersions)
+	}
+	if res.ScannedAt.IsZero() || res.IP != "127.0.0.1" {
+		t.Fatalf("unexpected result metadata: %+v", res)
+	}
+}
+
+func TestQueueSkipsRecentAndPending(t *testing.T) {
+	q := NewQueue(&Scanner{}, 60, time.Hour, nil)
+
+	if q.Enqueue("", "10.0.0.1", time.Time{}) {
+		t.Fatal("expected empty serial to be rejected")
+	}
+	if q.Enqueue("SN1", "10.0.0.1", time.Now().Add(-time.Minute)) {
+		t.Fatal("expected recently scanned device to be skipped")
+	}
+	if !q.Enqueue("SN1", "10.0.0.1", time.Now().Add(-2*time.Hour)) {
+		t.Fatal("expected stale device to be queued")
+	}
+	if q.Enqueue("SN1", "10.0.0.1", time.Time{}) {
+		t.Fatal("expected pending device not to be queued twice")
+	}
+}
+
+func TestQueueRunDeliversResults(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	got := make(chan string, 1)
+	q := NewQueue(&Scanner{}, 60, time.Hour, func(ctx context.Context, serial string, res Result) {
+		got <- serial
+	})
+	go q.Run(ctx)
+
+	if !q.Enqueue("SN1", "127.0.0.1", time.Time{}) {
+		t.Fatal("expected device to be queued")
+	}
+	select {
+	case serial := <-got:
+		if serial != "SN1" {
+			t.Fatalf("result for %q, want SN1", serial)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("timed out waiting for scan result")
+	}
+
+	// The completed scan is remembered for the rescan interval
+	if q.Enqueue("SN1", "127.0.0.1", time.Time{}) {
+		t.Fatal("expected device scanned moments ago to be skipped")
+	}
+}
@@ -7,17 +7,22 @@ import (
 	"time"
 
 	"printmaster/agent/agent"
+	"printmaster/agent/featureflags"
+	"printmaster/agent/scanner/vendor"
 	"printmaster/agent/storage"
 	pmsettings "printmaster/common/settings"
+	"printmaster/common/vendorrules"
 )
 
 const serverManagedSettingsKey = "server_managed_settings"
 
 type serverManagedSettings struct {
-	Version       string              `json:"version"`
-	SchemaVersion string              `json:"schema_version"`
-	UpdatedAt     time.Time           `json:"updated_at"`
-	Settings      pmsettings.Settings `json:"settings"`
+	Version        string              `json:"version"`
+	SchemaVersion  string              `json:"schema_version"`
+	UpdatedAt      time.Time           `json:"updated_at"`
+	Settings       pmsettings.Settings `json:"settings"`
+	FeatureFlags   map[string]bool     `json:"feature_flags,omitempty"`
+	DetectionRules []vendorrules.Rule  `json:"detection_rules,omitempty"`
 }
 
 // SettingsManager tracks server-managed snapshots and composes effective configs.
@@ -48,6 +53,8 @@ func (m *SettingsManager) reload() {
 	m.mu.Lock()
 	m.managed = &payload
 	m.mu.Unlock()
+	applyServerFeatureFlags(payload.FeatureFlags)
+	applyServerDetectionRules(payload.DetectionRules)
 }
 
 func (m *SettingsManager) CurrentVersion() string {
@@ -91,19 +98,33 @@ func (m *SettingsManager) ApplyServerSnapshot(snapshot *agent.SettingsSnapshot)
 		return pmsettings.Settings{}, fmt.Errorf("invalid snapshot")
 	}
 	payload := serverManagedSettings{
-		Version:       snapshot.Version,
-		SchemaVersion: snapshot.SchemaVersion,
-		UpdatedAt:     snapshot.UpdatedAt,
-		Settings:      snapshot.Settings,
+		Version:        snapshot.Version,
+		SchemaVersion:  snapshot.SchemaVersion,
+		UpdatedAt:      snapshot.UpdatedAt,
+		Settings:       snapshot.Settings,
+		FeatureFlags:   snapshot.FeatureFlags,
+		DetectionRules: snapshot.DetectionRules,
 	}
 	pmsettings.Sanitize(&payload.Settings)
+	if !pmsettings.FeatureEnabled(payload.FeatureFlags, pmsettings.FeatureJobAccounting) {
+		payload.Settings.Spooler.Enabled = false
+	}
+
+	settingsWriteMu.Lock()
+	defer settingsWriteMu.Unlock()
+	before := loadUnifiedSettings(m.store)
 	if err := m.store.SetConfigValue(serverManagedSettingsKey, payload); err != nil {
 		return pmsettings.Settings{}, err
 	}
 	m.mu.Lock()
 	m.managed = &payload
 	m.mu.Unlock()
-	return loadUnifiedSettings(m.store), nil
+	applyServerFeatureFlags(payload.FeatureFlags)
+	applyServerDetectionRules(payload.DetectionRules)
+	effective := loadUnifiedSettings(m.store)
+	// Local edits based on the values this push replaced now conflict
+	bumpSettingsRevisions(m.store, before, effective)
+	return effective, nil
 }
 
 // ClearManagedSnapshot removes the server-managed settings snapshot, unlocking
@@ -113,6 +134,9 @@ func (m *SettingsManager) ClearManagedSnapshot() error {
 	if m == nil || m.store == nil {
 		return nil
 	}
+	settingsWriteMu.Lock()
+	defer settingsWriteMu.Unlock()
+	before := loadUnifiedSettings(m.store)
 	// Delete the server managed settings key from storage
 	if err := m.store.DeleteConfigValue(serverManagedSettingsKey); err != nil {
 		return fmt.Errorf("failed to clear managed settings: %w", err)
@@ -120,5 +144,40 @@ func (m *SettingsManager) ClearManagedSnapshot() error {
 	m.mu.Lock()
 	m.managed = nil
 	m.mu.Unlock()
+	bumpSettingsRevisions(m.store, before, loadUnifiedSettings(m.store))
+	applyServerFeatureFlags(nil)
+	applyServerDetectionRules(nil)
+	defaults := pmsettings.DefaultSettings()
+	applySNMPSweepSettings(&defaults.SNMP)
 	return nil
 }
+
+// applyServerFeatureFlags updates the runtime entitlement toggles from the
+// server's feature flags. Missing flags (standalone agents, older servers)
+// fall back to their defaults.
+func applyServerFeatureFlags(flags map[string]bool) {
+	remoteProxy := pmsettings.FeatureEnabled(flags, pmsettings.FeatureRemoteProxy)
+	firmwareUpdates := pmsettings.FeatureEnabled(flags, pmsettings.FeatureFirmwareUpdates)
+	changed := featureflags.RemoteProxyEnabled() != remoteProxy || featureflags.FirmwareUpdatesEnabled() != firmwareUpdates
+	featureflags.SetRemoteProxy(remoteProxy)
+	featureflags.SetFirmwareUpdates(firmwareUpdates)
+	if changed && appLogger != nil {
+		appLogger.Info("Server feature flags updated", "remote_proxy", remoteProxy, "firmware_updates", firmwareUpdates)
+	}
+}
+
+// applyServerDetectionRules installs the server-managed vendor detection
+// rules. Without a server snapshot only the built-in detection applies.
+func applyServerDetectionRules(rules []vendorrules.Rule) {
+	previous := vendor.DetectionRuleCount()
+	err := vendor.SetDetectionRules(rules)
+	if appLogger == nil {
+		return
+	}
+	if err != nil {
+		appLogger.Warn("Skipped invalid detection rules", "error", err)
+	}
+	if count := vendor.DetectionRuleCount(); count != previous {
+		appLogger.Info("Detection rules updated", "rules", count)
+	}
+}
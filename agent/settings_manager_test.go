@@ -5,7 +5,9 @@ import (
 	"time"
 
 	agentpkg "printmaster/agent/agent"
+	"printmaster/agent/scanner/vendor"
 	pmsettings "printmaster/common/settings"
+	"printmaster/common/vendorrules"
 )
 
 func TestSettingsManagerReloadsPersistedSnapshot(t *testing.T) {
@@ -118,3 +120,40 @@ func TestSettingsManagerClearManagedSnapshotUnlocksSettings(t *testing.T) {
 		t.Fatalf("expected default settings after clear")
 	}
 }
+
+func TestSettingsManagerAppliesDetectionRules(t *testing.T) {
+	store := newFakeConfigStore()
+	mgr := NewSettingsManager(store)
+	t.Cleanup(func() { vendor.SetDetectionRules(nil) })
+
+	snap := &agentpkg.SettingsSnapshot{
+		Version:  "hash-rules",
+		Settings: pmsettings.DefaultSettings(),
+		DetectionRules: []vendorrules.Rule{
+			{ID: 7, Manufacturer: "Acme", SysObjectIDPrefix: "1.3.6.1.4.1.55555", VendorModule: "HP", Enabled: true},
+		},
+	}
+	if _, err := mgr.ApplyServerSnapshot(snap); err != nil {
+		t.Fatalf("ApplyServerSnapshot: %v", err)
+	}
+	if m, ok := vendor.MatchDetectionRule("1.3.6.1.4.1.55555.1", ""); !ok || m.Manufacturer != "Acme" {
+		t.Fatalf("expected rule to be active, got %+v (%v)", m, ok)
+	}
+	if got := vendor.DetectVendor("1.3.6.1.4.1.55555.1", "", ""); got.Name() != "HP" {
+		t.Fatalf("expected rule to select the HP module, got %s", got.Name())
+	}
+
+	// Reloading from the persisted snapshot keeps the rules
+	vendor.SetDetectionRules(nil)
+	NewSettingsManager(store)
+	if vendor.DetectionRuleCount() != 1 {
+		t.Fatalf("expected persisted rules to be reapplied")
+	}
+
+	if err := mgr.ClearManagedSnapshot(); err != nil {
+		t.Fatalf("ClearManagedSnapshot: %v", err)
+	}
+	if vendor.DetectionRuleCount() != 0 {
+		t.Fatalf("expected rules to be cleared with the managed snapshot")
+	}
+}
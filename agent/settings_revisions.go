@@ -0,0 +1,169 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+
+	"printmaster/agent/storage"
+	pmsettings "printmaster/common/settings"
+)
+
+// Settings sections carry a revision number that increases whenever their
+// values change, whether from the local settings page or a server push.
+// Writers may send the revisions they last read (a "revisions" body field or
+// an If-Match header with the ETag from GET /settings); a write to a section
+// that changed in the meantime is refused with 409 instead of silently
+// overwriting the newer values.
+
+const settingsRevisionsKey = "settings_revisions"
+
+// settingsSections lists the /settings sections in ETag order.
+var settingsSections = []string{"discovery", "snmp", "features", "spooler", "logging", "web", "notifications"}
+
+// settingsWriteMu serializes read-modify-write cycles on the settings so a
+// revision check and the write it guards cannot interleave with another.
+var settingsWriteMu sync.Mutex
+
+// settingsConflict describes a section whose revision moved on since the
+// caller read it.
+type settingsConflict struct {
+	Section  string `json:"section"`
+	Expected int64  `json:"expected"`
+	Current  int64  `json:"current"`
+}
+
+// loadSettingsRevisions returns the stored revision of every section (0 for
+// sections never changed).
+func loadSettingsRevisions(store storage.AgentConfigStore) map[string]int64 {
+	revs := make(map[string]int64, len(settingsSections))
+	if store != nil {
+		_ = store.GetConfigValue(settingsRevisionsKey, &revs)
+	}
+	for _, section := range settingsSections {
+		if _, ok := revs[section]; !ok {
+			revs[section] = 0
+		}
+	}
+	return revs
+}
+
+// bumpSettingsRevisions increments the revision of each section whose values
+// differ between before and after, and returns the resulting revisions.
+func bumpSettingsRevisions(store storage.AgentConfigStore, before, after pmsettings.Settings) map[string]int64 {
+	revs := loadSettingsRevisions(store)
+	old, cur := settingsSectionValues(before), settingsSectionValues(after)
+	changed := false
+	for _, section := range settingsSections {
+		if !reflect.DeepEqual(old[section], cur[section]) {
+			revs[section]++
+			changed = true
+		}
+	}
+	if changed && store != nil {
+		if err := store.SetConfigValue(settingsRevisionsKey, revs); err != nil && appLogger != nil {
+			appLogger.Warn("Failed to save settings revisions", "error", err)
+		}
+	}
+	return revs
+}
+
+func settingsSectionValues(cfg pmsettings.Settings) map[string]map[string]interface{} {
+	disc := structToMap(cfg.Discovery)
+	// Derived from the host, not part of the stored settings
+	delete(disc, "detected_subnet")
+	return map[string]map[string]interface{}{
+		"discovery":     disc,
+		"snmp":          structToMap(cfg.SNMP),
+		"features":      structToMap(cfg.Features),
+		"spooler":       structToMap(cfg.Spooler),
+		"logging":       structToMap(cfg.Logging),
+		"web":           structToMap(cfg.Web),
+		"notifications": structToMap(cfg.Notifications),
+	}
+}
+
+// settingsETag encodes the section revisions as an entity tag, e.g.
+// "discovery.3;snmp.1;features.0;...".
+func settingsETag(revs map[string]int64) string {
+	parts := make([]string, 0, len(settingsSections))
+	for _, section := range settingsSections {
+		parts = append(parts, fmt.Sprintf("%s.%d", section, revs[section]))
+	}
+	return `"` + strings.Join(parts, ";") + `"`
+}
+
+// parseSettingsETag decodes an If-Match value produced by settingsETag.
+func parseSettingsETag(value string) (map[string]int64, error) {
+	value = strings.TrimPrefix(strings.TrimSpace(value), "W/")
+	value = strings.Trim(value, `"`)
+	revs := map[string]int64{}
+	for _, part := range strings.Split(value, ";") {
+		section, rev, ok := strings.Cut(part, ".")
+		if !ok {
+			return nil, fmt.Errorf("invalid settings revision %q", part)
+		}
+		n, err := strconv.ParseInt(rev, 10, 64)
+		if err != nil || n < 0 {
+			return nil, fmt.Errorf("invalid settings revision %q", part)
+		}
+		revs[section] = n
+	}
+	return revs, nil
+}
+
+// expectedSettingsRevisions returns the revisions the caller based its write
+// on: the body's revisions, else the If-Match header. nil means the caller
+// did not ask for a check.
+func expectedSettingsRevisions(r *http.Request, body map[string]int64) (map[string]int64, error) {
+	if len(body) > 0 {
+		return body, nil
+	}
+	ifMatch := strings.TrimSpace(r.Header.Get("If-Match"))
+	if ifMatch == "" || ifMatch == "*" {
+		return nil, nil
+	}
+	return parseSettingsETag(ifMatch)
+}
+
+// settingsRevisionConflicts compares the expected revisions of the written
+// sections against the current ones. Sections without an expected revision
+// are not checked.
+func settingsRevisionConflicts(expected, current map[string]int64, written []string) []settingsConflict {
+	var conflicts []settingsConflict
+	for _, section := range written {
+		want, ok := expected[section]
+		if !ok || want == current[section] {
+			continue
+		}
+		conflicts = append(conflicts, settingsConflict{Section: section, Expected: want, Current: current[section]})
+	}
+	return conflicts
+}
+
+// writeSettingsConflict answers 409 with the latest revisions.
+func writeSettingsConflict(w http.ResponseWriter, conflicts []settingsConflict, current map[string]int64) {
+	sections := make([]string, 0, len(conflicts))
+	for _, c := range conflicts {
+		sections = append(sections, c.Section)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("ETag", settingsETag(current))
+	w.WriteHeader(http.StatusConflict)
+	_ = json.NewEncoder(w).Encode(map[string]interface{}{
+		"error":     "settings changed since they were loaded: " + strings.Join(sections, ", "),
+		"conflicts": conflicts,
+		"revisions": current,
+	})
+}
+
+// settingsWithRevisions renders settings for a response with their revisions.
+func settingsWithRevisions(cfg pmsettings.Settings, revs map[string]int64) map[string]interface{} {
+	resp := structToMap(cfg)
+	resp["revisions"] = revs
+	return resp
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	agentpkg "printmaster/agent/agent"
+	pmsettings "printmaster/common/settings"
+)
+
+func TestSettingsETagRoundTrip(t *testing.T) {
+	revs := map[string]int64{"discovery": 3, "snmp": 1}
+	etag := settingsETag(revs)
+	if etag != `"discovery.3;snmp.1;features.0;spooler.0;logging.0;web.0;notifications.0"` {
+		t.Fatalf("unexpected etag %s", etag)
+	}
+	parsed, err := parseSettingsETag("W/" + etag)
+	if err != nil || parsed["discovery"] != 3 || parsed["snmp"] != 1 || parsed["web"] != 0 {
+		t.Fatalf("parseSettingsETag = %v, %v", parsed, err)
+	}
+	if _, err := parseSettingsETag(`"discovery"`); err == nil {
+		t.Error("expected error for malformed etag")
+	}
+
+	req := httptest.NewRequest("POST", "/settings", nil)
+	req.Header.Set("If-Match", etag)
+	expected, err := expectedSettingsRevisions(req, nil)
+	if err != nil || expected["discovery"] != 3 {
+		t.Fatalf("expectedSettingsRevisions(If-Match) = %v, %v", expected, err)
+	}
+	// The body takes precedence over the header
+	expected, _ = expectedSettingsRevisions(req, map[string]int64{"snmp": 7})
+	if expected["snmp"] != 7 {
+		t.Errorf("expected body revisions, got %v", expected)
+	}
+	req.Header.Set("If-Match", "*")
+	if expected, _ := expectedSettingsRevisions(req, nil); expected != nil {
+		t.Errorf("If-Match * should skip the check, got %v", expected)
+	}
+}
+
+func TestSettingsRevisionConflicts(t *testing.T) {
+	current := map[string]int64{"discovery": 4, "snmp": 2, "logging": 1}
+	expected := map[string]int64{"discovery": 3, "snmp": 2, "logging": 0}
+
+	// Only written sections are checked
+	conflicts := settingsRevisionConflicts(expected, current, []string{"snmp"})
+	if len(conflicts) != 0 {
+		t.Fatalf("unexpected conflicts %+v", conflicts)
+	}
+	conflicts = settingsRevisionConflicts(expected, current, []string{"discovery", "snmp", "web"})
+	if len(conflicts) != 1 || conflicts[0].Section != "discovery" || conflicts[0].Expected != 3 || conflicts[0].Current != 4 {
+		t.Fatalf("unexpected conflicts %+v", conflicts)
+	}
+	if conflicts := settingsRevisionConflicts(nil, current, settingsSections); len(conflicts) != 0 {
+		t.Errorf("writes without revisions must not conflict, got %+v", conflicts)
+	}
+}
+
+func TestServerSnapshotBumpsChangedSectionRevisions(t *testing.T) {
+	store := newFakeConfigStore()
+	mgr := NewSettingsManager(store)
+	prev := settingsManager
+	settingsManager = mgr
+	t.Cleanup(func() { settingsManager = prev })
+
+	snap := &agentpkg.SettingsSnapshot{
+		Version:   "v1",
+		UpdatedAt: time.Unix(100, 0),
+		Settings:  pmsettings.DefaultSettings(),
+	}
+	snap.Settings.SNMP.TimeoutMS = 4321
+	if _, err := mgr.ApplyServerSnapshot(snap); err != nil {
+		t.Fatalf("ApplyServerSnapshot: %v", err)
+	}
+	revs := loadSettingsRevisions(store)
+	if revs["snmp"] != 1 {
+		t.Errorf("snmp revision = %d, want 1", revs["snmp"])
+	}
+	if revs["logging"] != 0 || revs["web"] != 0 {
+		t.Errorf("unchanged sections were bumped: %v", revs)
+	}
+
+	// Re-applying identical settings is not a change
+	snap.Version = "v2"
+	if _, err := mgr.ApplyServerSnapshot(snap); err != nil {
+		t.Fatalf("ApplyServerSnapshot: %v", err)
+	}
+	if got := loadSettingsRevisions(store)["snmp"]; got != 1 {
+		t.Errorf("snmp revision after identical push = %d, want 1", got)
+	}
+
+	// An edit based on the pre-push revision now conflicts
+	if conflicts := settingsRevisionConflicts(map[string]int64{"snmp": 0}, loadSettingsRevisions(store), []string{"snmp"}); len(conflicts) != 1 {
+		t.Errorf("expected stale local edit to conflict, got %+v", conflicts)
+	}
+}
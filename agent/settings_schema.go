@@ -0,0 +1,90 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"sort"
+
+	pmsettings "printmaster/common/settings"
+)
+
+// POST /settings bodies are checked against the settings JSON Schema before
+// anything is written, so a typo or out-of-range value is reported with the
+// exact field instead of being dropped or clamped later. GET /settings/schema
+// serves the same schema so UIs can render forms for new settings.
+
+// settingsControlKeys are the non-section fields a POST /settings body may
+// carry, including the read-only GET fields clients echo back.
+var settingsControlKeys = map[string]bool{
+	"reset":                           true,
+	"override_safety_limits":          true,
+	"revisions":                       true,
+	"server_managed":                  true,
+	"managed_sections":                true,
+	"desktop_notifications_available": true,
+}
+
+// settingsSchema is built once; the settings types do not change at runtime.
+var settingsSchema = pmsettings.SettingsJSONSchema()
+
+// handleSettingsSchema serves the JSON Schema of the /settings document.
+func handleSettingsSchema(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	w.Header().Set("Content-Type", "application/schema+json")
+	_ = json.NewEncoder(w).Encode(settingsSchema)
+}
+
+// validateSettingsBody checks the sections of a POST /settings body against
+// the schema. Only the fields present are checked, so partial updates stay
+// valid. A body that is not a JSON object is reported as an error.
+func validateSettingsBody(body []byte) ([]pmsettings.ValidationError, error) {
+	var doc map[string]json.RawMessage
+	if err := json.Unmarshal(body, &doc); err != nil {
+		return nil, err
+	}
+	keys := make([]string, 0, len(doc))
+	for k := range doc {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var issues []pmsettings.ValidationError
+	for _, key := range keys {
+		if settingsControlKeys[key] {
+			continue
+		}
+		raw := doc[key]
+		if _, ok := settingsSchema.Properties[key]; !ok {
+			issues = append(issues, pmsettings.ValidationError{Field: key, Path: "/" + key, Code: pmsettings.CodeUnknownField, Message: "unknown settings section"})
+			continue
+		}
+		if string(bytes.TrimSpace(raw)) == "null" {
+			continue // omitted section
+		}
+		var values interface{}
+		if err := json.Unmarshal(raw, &values); err != nil {
+			return nil, err
+		}
+		obj, ok := values.(map[string]interface{})
+		if !ok {
+			issues = append(issues, pmsettings.ValidationError{Field: key, Path: "/" + key, Code: pmsettings.CodeType, Message: "must be an object"})
+			continue
+		}
+		issues = append(issues, pmsettings.ValidateSection(settingsSchema, key, obj)...)
+	}
+	return issues, nil
+}
+
+// writeSettingsValidationErrors responds 400 with the failed checks.
+func writeSettingsValidationErrors(w http.ResponseWriter, issues []pmsettings.ValidationError) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusBadRequest)
+	_ = json.NewEncoder(w).Encode(map[string]interface{}{
+		"error":  "settings validation failed",
+		"errors": issues,
+	})
+}
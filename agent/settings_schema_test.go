@@ -0,0 +1,72 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	pmsettings "printmaster/common/settings"
+)
+
+func TestValidateSettingsBody(t *testing.T) {
+	ok := `{"snmp":{"timeout_ms":2000,"retries":1},"logging":{"level":"debug"},"discovery":null,"reset":false,"revisions":{"snmp":2}}`
+	if issues, err := validateSettingsBody([]byte(ok)); err != nil || len(issues) != 0 {
+		t.Fatalf("valid body rejected: %+v, %v", issues, err)
+	}
+
+	bad := `{"snmp":{"timeout_ms":"2000"},"discovery":{"concurrency":999,"subnet_scn":true},"printers":{},"web":[]}`
+	issues, err := validateSettingsBody([]byte(bad))
+	if err != nil {
+		t.Fatalf("validateSettingsBody: %v", err)
+	}
+	want := map[string]string{
+		"/discovery/concurrency": pmsettings.CodeMaximum,
+		"/discovery/subnet_scn":  pmsettings.CodeUnknownField,
+		"/printers":              pmsettings.CodeUnknownField,
+		"/snmp/timeout_ms":       pmsettings.CodeType,
+		"/web":                   pmsettings.CodeType,
+	}
+	if len(issues) != len(want) {
+		t.Fatalf("expected %d issues, got %+v", len(want), issues)
+	}
+	for _, issue := range issues {
+		if want[issue.Path] != issue.Code {
+			t.Errorf("unexpected issue %+v", issue)
+		}
+	}
+
+	if _, err := validateSettingsBody([]byte(`[1]`)); err == nil {
+		t.Error("expected error for non-object body")
+	}
+
+	rr := httptest.NewRecorder()
+	writeSettingsValidationErrors(rr, issues)
+	var resp struct {
+		Error  string                       `json:"error"`
+		Errors []pmsettings.ValidationError `json:"errors"`
+	}
+	if rr.Code != http.StatusBadRequest || json.Unmarshal(rr.Body.Bytes(), &resp) != nil || len(resp.Errors) != len(want) {
+		t.Fatalf("unexpected response %d %s", rr.Code, rr.Body.String())
+	}
+}
+
+func TestHandleSettingsSchema(t *testing.T) {
+	rr := httptest.NewRecorder()
+	handleSettingsSchema(rr, httptest.NewRequest(http.MethodGet, "/settings/schema", nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", rr.Code)
+	}
+	var schema pmsettings.JSONSchema
+	if err := json.Unmarshal(rr.Body.Bytes(), &schema); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	for _, section := range settingsSections {
+		if schema.Properties[section] == nil {
+			t.Errorf("schema missing section %s", section)
+		}
+	}
+	if got := requiredAgentRole(httptest.NewRequest(http.MethodGet, "/settings/schema", nil)); got != agentRoleViewer {
+		t.Errorf("schema role = %s, want viewer", got)
+	}
+}
@@ -0,0 +1,402 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"printmaster/agent/scanner"
+	"printmaster/agent/storage"
+	commonutil "printmaster/common/util"
+
+	"github.com/gosnmp/gosnmp"
+)
+
+// snmpProfileMatchesKey stores the credential profile that last worked for
+// each device, keyed by device IP.
+const snmpProfileMatchesKey = "snmp_profile_matches"
+
+var (
+	snmpProfilesMu     sync.Mutex
+	snmpProfilesStore  storage.AgentConfigStore
+	snmpProfilesSecret []byte
+)
+
+// initSNMPProfiles loads the SNMP credential profiles into the scanner and
+// starts remembering which profile works for each device. Community strings
+// and passwords are encrypted with the agent secret key.
+func initSNMPProfiles(store storage.AgentConfigStore, secret []byte) {
+	snmpProfilesMu.Lock()
+	snmpProfilesStore = store
+	snmpProfilesSecret = secret
+	snmpProfilesMu.Unlock()
+	if store == nil {
+		return
+	}
+	var remembered map[string]int64
+	if err := store.GetConfigValue(snmpProfileMatchesKey, &remembered); err == nil && len(remembered) > 0 {
+		scanner.LoadRememberedProfiles(remembered)
+	}
+	scanner.SetCredentialProfileObserver(rememberSNMPProfile)
+	if n := reloadSNMPProfiles(); n > 0 && appLogger != nil {
+		appLogger.Info("Loaded SNMP credential profiles", "profiles", n, "devices", len(remembered))
+	}
+}
+
+// reloadSNMPProfiles hands the stored profiles to the scanner and returns
+// how many are active. Profiles that cannot be decrypted or parsed are skipped.
+func reloadSNMPProfiles() int {
+	snmpProfilesMu.Lock()
+	store, secret := snmpProfilesStore, snmpProfilesSecret
+	snmpProfilesMu.Unlock()
+	if store == nil {
+		return 0
+	}
+	saved, err := store.ListSNMPProfiles()
+	if err != nil {
+		if appLogger != nil {
+			appLogger.Warn("Failed to load SNMP credential profiles", "error", err)
+		}
+		return 0
+	}
+	profiles := make([]scanner.CredentialProfile, 0, len(saved))
+	for _, p := range saved {
+		plain, err := decryptSNMPProfile(p, secret)
+		if err == nil {
+			var cp scanner.CredentialProfile
+			if cp, err = credentialProfileFrom(plain); err == nil {
+				profiles = append(profiles, cp)
+				continue
+			}
+		}
+		if appLogger != nil {
+			appLogger.Warn("Ignoring SNMP credential profile", "profile", p.Name, "error", err)
+		}
+	}
+	scanner.SetCredentialProfiles(profiles)
+	return len(profiles)
+}
+
+// rememberSNMPProfile persists the profile that worked for ip.
+func rememberSNMPProfile(ip string, profileID int64) {
+	if appLogger != nil {
+		appLogger.Info("SNMP credential profile matched", "ip", ip, "profile_id", profileID)
+	}
+	snmpProfilesMu.Lock()
+	defer snmpProfilesMu.Unlock()
+	if snmpProfilesStore == nil || ip == "" {
+		return
+	}
+	remembered := map[string]int64{}
+	_ = snmpProfilesStore.GetConfigValue(snmpProfileMatchesKey, &remembered)
+	if remembered == nil {
+		remembered = map[string]int64{}
+	}
+	remembered[ip] = profileID
+	if err := snmpProfilesStore.SetConfigValue(snmpProfileMatchesKey, remembered); err != nil && appLogger != nil {
+		appLogger.Warn("Failed to save SNMP credential profile match", "ip", ip, "error", err)
+	}
+}
+
+// forgetSNMPProfileMatches drops remembered matches for a deleted profile and
+// returns how many devices used it.
+func forgetSNMPProfileMatches(profileID int64) int {
+	snmpProfilesMu.Lock()
+	defer snmpProfilesMu.Unlock()
+	if snmpProfilesStore == nil {
+		return 0
+	}
+	remembered := map[string]int64{}
+	_ = snmpProfilesStore.GetConfigValue(snmpProfileMatchesKey, &remembered)
+	dropped := 0
+	for ip, id := range remembered {
+		if id == profileID {
+			delete(remembered, ip)
+			dropped++
+		}
+	}
+	if dropped == 0 {
+		return 0
+	}
+	scanner.LoadRememberedProfiles(remembered)
+	if err := snmpProfilesStore.SetConfigValue(snmpProfileMatchesKey, remembered); err != nil && appLogger != nil {
+		appLogger.Warn("Failed to save SNMP credential profile matches", "error", err)
+	}
+	return dropped
+}
+
+// credentialProfileFrom validates a stored profile (with plaintext secrets)
+// and converts it for the scanner.
+func credentialProfileFrom(p storage.SNMPProfile) (scanner.CredentialProfile, error) {
+	cp := scanner.CredentialProfile{ID: p.ID, Name: p.Name, Priority: p.Priority}
+	if strings.TrimSpace(p.Name) == "" {
+		return cp, errors.New("name required")
+	}
+	for _, s := range p.Subnets {
+		s = strings.TrimSpace(s)
+		if !strings.Contains(s, "/") {
+			if ip := net.ParseIP(s); ip != nil && ip.To4() != nil {
+				s += "/32"
+			} else {
+				s += "/128"
+			}
+		}
+		_, n, err := net.ParseCIDR(s)
+		if err != nil {
+			return cp, fmt.Errorf("invalid subnet %q", s)
+		}
+		cp.Subnets = append(cp.Subnets, n)
+	}
+
+	version, err := scanner.ParseSNMPVersion(p.Version)
+	if err != nil {
+		return cp, err
+	}
+	cfg := scanner.SNMPConfig{Version: version}
+	if version != gosnmp.Version3 {
+		if p.Community == "" {
+			return cp, errors.New("community required for SNMPv1/v2c")
+		}
+		cfg.Community = p.Community
+		cp.Config = cfg
+		return cp, nil
+	}
+
+	if strings.TrimSpace(p.Username) == "" {
+		return cp, errors.New("username required for SNMPv3")
+	}
+	cfg.Username = p.Username
+	cfg.ContextName = p.ContextName
+	if cfg.SecurityLevel, err = scanner.ParseSNMPSecurityLevel(p.SecurityLevel); err != nil {
+		return cp, err
+	}
+	if cfg.SecurityLevel != gosnmp.NoAuthNoPriv {
+		if cfg.AuthProtocol, err = scanner.ParseSNMPAuthProtocol(p.AuthProtocol); err != nil {
+			return cp, err
+		}
+		if cfg.AuthProtocol == gosnmp.NoAuth {
+			return cp, errors.New("auth protocol required for authNoPriv and authPriv")
+		}
+		// USM derives keys from passphrases of at least 8 characters
+		if len(p.AuthPassword) < 8 {
+			return cp, errors.New("auth password must be at least 8 characters")
+		}
+		cfg.AuthPassword = p.AuthPassword
+	}
+	if cfg.SecurityLevel == gosnmp.AuthPriv {
+		if cfg.PrivProtocol, err = scanner.ParseSNMPPrivProtocol(p.PrivProtocol); err != nil {
+			return cp, err
+		}
+		if cfg.PrivProtocol == gosnmp.NoPriv {
+			return cp, errors.New("privacy protocol required for authPriv")
+		}
+		if len(p.PrivPassword) < 8 {
+			return cp, errors.New("privacy password must be at least 8 characters")
+		}
+		cfg.PrivPassword = p.PrivPassword
+	}
+	cp.Config = cfg
+	return cp, nil
+}
+
+func encryptSNMPProfile(p storage.SNMPProfile, secret []byte) (storage.SNMPProfile, error) {
+	for _, field := range []*string{&p.Community, &p.AuthPassword, &p.PrivPassword} {
+		if *field == "" {
+			continue
+		}
+		enc, err := commonutil.EncryptToB64(secret, *field)
+		if err != nil {
+			return p, err
+		}
+		*field = enc
+	}
+	return p, nil
+}
+
+func decryptSNMPProfile(p storage.SNMPProfile, secret []byte) (storage.SNMPProfile, error) {
+	for _, field := range []*string{&p.Community, &p.AuthPassword, &p.PrivPassword} {
+		if *field == "" {
+			continue
+		}
+		plain, err := commonutil.DecryptFromB64(secret, *field)
+		if err != nil {
+			return p, fmt.Errorf("cannot decrypt credentials: %w", err)
+		}
+		*field = plain
+	}
+	return p, nil
+}
+
+// snmpProfileView is a profile as reported by the API: community strings and
+// passwords are never sent back.
+type snmpProfileView struct {
+	ID              int64     `json:"id"`
+	Name            string    `json:"name"`
+	Priority        int       `json:"priority"`
+	Subnets         []string  `json:"subnets,omitempty"`
+	Version         string    `json:"version"`
+	SecurityLevel   string    `json:"security_level,omitempty"`
+	Username        string    `json:"username,omitempty"`
+	AuthProtocol    string    `json:"auth_protocol,omitempty"`
+	PrivProtocol    string    `json:"priv_protocol,omitempty"`
+	ContextName     string    `json:"context_name,omitempty"`
+	HasCommunity    bool      `json:"has_community"`
+	HasAuthPassword bool      `json:"has_auth_password"`
+	HasPrivPassword bool      `json:"has_priv_password"`
+	Devices         []string  `json:"devices,omitempty"` // IPs this profile last worked for
+	UpdatedAt       time.Time `json:"updated_at"`
+}
+
+func newSNMPProfileView(p storage.SNMPProfile, devices []string) snmpProfileView {
+	return snmpProfileView{
+		ID: p.ID, Name: p.Name, Priority: p.Priority, Subnets: p.Subnets, Version: p.Version,
+		SecurityLevel: p.SecurityLevel, Username: p.Username, AuthProtocol: p.AuthProtocol,
+		PrivProtocol: p.PrivProtocol, ContextName: p.ContextName,
+		HasCommunity: p.Community != "", HasAuthPassword: p.AuthPassword != "", HasPrivPassword: p.PrivPassword != "",
+		Devices: devices, UpdatedAt: p.UpdatedAt,
+	}
+}
+
+// registerSNMPProfileHandlers exposes SNMP credential profiles.
+func registerSNMPProfileHandlers() {
+	// GET    /api/snmp/profiles       - list profiles in the order they are tried
+	// POST   /api/snmp/profiles       - create (no id) or update a profile; empty secrets keep the saved ones
+	// DELETE /api/snmp/profiles?id=N  - delete a profile
+	http.HandleFunc("/api/snmp/profiles", handleSNMPProfiles)
+}
+
+func handleSNMPProfiles(w http.ResponseWriter, r *http.Request) {
+	snmpProfilesMu.Lock()
+	store, secret := snmpProfilesStore, snmpProfilesSecret
+	snmpProfilesMu.Unlock()
+	if store == nil {
+		http.Error(w, "agent config storage unavailable", http.StatusServiceUnavailable)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		saved, err := store.ListSNMPProfiles()
+		if err != nil {
+			http.Error(w, "failed to list profiles", http.StatusInternalServerError)
+			return
+		}
+		remembered := map[string]int64{}
+		_ = store.GetConfigValue(snmpProfileMatchesKey, &remembered)
+		devices := map[int64][]string{}
+		for ip, id := range remembered {
+			devices[id] = append(devices[id], ip)
+		}
+		out := make([]snmpProfileView, 0, len(saved))
+		for _, p := range saved {
+			out = append(out, newSNMPProfileView(p, devices[p.ID]))
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(out)
+	case http.MethodPost:
+		var req storage.SNMPProfile
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "bad json", http.StatusBadRequest)
+			return
+		}
+		req.Name = strings.TrimSpace(req.Name)
+		if len(secret) != 32 {
+			http.Error(w, "secret key unavailable; cannot store SNMP credentials", http.StatusInternalServerError)
+			return
+		}
+		if req.ID != 0 {
+			if err := keepSavedSNMPSecrets(store, secret, &req); err != nil {
+				status := http.StatusInternalServerError
+				if errors.Is(err, storage.ErrNotFound) {
+					status = http.StatusNotFound
+				}
+				http.Error(w, err.Error(), status)
+				return
+			}
+		}
+		if _, err := credentialProfileFrom(req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		enc, err := encryptSNMPProfile(req, secret)
+		if err != nil {
+			http.Error(w, "failed to encrypt credentials", http.StatusInternalServerError)
+			return
+		}
+		if err := store.SaveSNMPProfile(&enc); err != nil {
+			if errors.Is(err, storage.ErrNotFound) {
+				http.Error(w, "profile not found", http.StatusNotFound)
+				return
+			}
+			if appLogger != nil {
+				appLogger.Warn("Failed to save SNMP credential profile", "profile", req.Name, "error", err)
+			}
+			http.Error(w, "failed to save profile (names must be unique)", http.StatusInternalServerError)
+			return
+		}
+		reloadSNMPProfiles()
+		if appLogger != nil {
+			appLogger.Info("SNMP credential profile saved", "id", enc.ID, "profile", enc.Name,
+				"version", enc.Version, "security_level", enc.SecurityLevel, "subnets", len(enc.Subnets))
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(newSNMPProfileView(enc, nil))
+	case http.MethodDelete:
+		id, err := strconv.ParseInt(r.URL.Query().Get("id"), 10, 64)
+		if err != nil || id <= 0 {
+			http.Error(w, "id required", http.StatusBadRequest)
+			return
+		}
+		if err := store.DeleteSNMPProfile(id); err != nil {
+			if errors.Is(err, storage.ErrNotFound) {
+				http.Error(w, "profile not found", http.StatusNotFound)
+				return
+			}
+			http.Error(w, "failed to delete profile", http.StatusInternalServerError)
+			return
+		}
+		dropped := forgetSNMPProfileMatches(id)
+		reloadSNMPProfiles()
+		if appLogger != nil {
+			appLogger.Info("SNMP credential profile deleted", "id", id, "devices", dropped)
+		}
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		http.Error(w, "GET, POST or DELETE only", http.StatusMethodNotAllowed)
+	}
+}
+
+// keepSavedSNMPSecrets fills secrets left empty in an update from the saved
+// profile, so clients can edit a profile without resending its passwords.
+func keepSavedSNMPSecrets(store storage.AgentConfigStore, secret []byte, req *storage.SNMPProfile) error {
+	saved, err := store.ListSNMPProfiles()
+	if err != nil {
+		return fmt.Errorf("failed to load profile: %w", err)
+	}
+	for _, p := range saved {
+		if p.ID != req.ID {
+			continue
+		}
+		plain, err := decryptSNMPProfile(p, secret)
+		if err != nil {
+			return err
+		}
+		if req.Community == "" {
+			req.Community = plain.Community
+		}
+		if req.AuthPassword == "" {
+			req.AuthPassword = plain.AuthPassword
+		}
+		if req.PrivPassword == "" {
+			req.PrivPassword = plain.PrivPassword
+		}
+		return nil
+	}
+	return fmt.Errorf("profile not found: %w", storage.ErrNotFound)
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"printmaster/agent/scanner"
+
+	"github.com/gosnmp/gosnmp"
+)
+
+func TestSNMPProfileHandlerStoresEncryptedProfiles(t *testing.T) {
+	store := newFakeConfigStore()
+	secret := []byte("0123456789abcdef0123456789abcdef")
+	initSNMPProfiles(store, secret)
+	t.Cleanup(func() {
+		scanner.SetCredentialProfiles(nil)
+		scanner.SetCredentialProfileObserver(nil)
+		scanner.LoadRememberedProfiles(nil)
+		initSNMPProfiles(nil, nil)
+	})
+
+	do := func(method, target, body string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		handleSNMPProfiles(rec, httptest.NewRequest(method, target, strings.NewReader(body)))
+		return rec
+	}
+
+	if rec := do(http.MethodPost, "/api/snmp/profiles", `{"name":"weak","version":"3","username":"u","security_level":"authPriv","auth_protocol":"SHA","auth_password":"short"}`); rec.Code != http.StatusBadRequest {
+		t.Fatalf("short password: status %d", rec.Code)
+	}
+	rec := do(http.MethodPost, "/api/snmp/profiles", `{"name":"office","priority":10,"subnets":["10.8.0.0/24","10.9.0.5"],"version":"3",
+		"username":"printers","security_level":"authPriv","auth_protocol":"SHA256","auth_password":"auth-secret","priv_protocol":"AES","priv_password":"priv-secret"}`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("POST: status %d body %s", rec.Code, rec.Body.String())
+	}
+	if strings.Contains(rec.Body.String(), "secret") {
+		t.Fatalf("response leaks credentials: %s", rec.Body.String())
+	}
+	if saved := store.snmpProfiles[0]; saved.AuthPassword == "auth-secret" || saved.PrivPassword == "priv-secret" {
+		t.Fatal("passwords stored in plaintext")
+	}
+
+	p, ok := scannerProfile(t, 1)
+	if !ok || p.Config.SecurityLevel != gosnmp.AuthPriv || p.Config.AuthPassword != "auth-secret" || !p.Matches("10.9.0.5") || p.Matches("10.9.0.6") {
+		t.Fatalf("profile not handed to the scanner: %+v", p)
+	}
+
+	// Updating without passwords keeps the saved ones
+	if rec := do(http.MethodPost, "/api/snmp/profiles", `{"id":1,"name":"office","priority":5,"version":"3","username":"printers",
+		"security_level":"authPriv","auth_protocol":"SHA256","priv_protocol":"AES"}`); rec.Code != http.StatusOK {
+		t.Fatalf("update: status %d body %s", rec.Code, rec.Body.String())
+	}
+	if p, _ := scannerProfile(t, 1); p.Priority != 5 || p.Config.PrivPassword != "priv-secret" {
+		t.Fatalf("update lost fields: %+v", p)
+	}
+
+	// Matches are remembered and listed per profile
+	rememberSNMPProfile("10.8.0.7", 1)
+	rec = do(http.MethodGet, "/api/snmp/profiles", "")
+	var views []snmpProfileView
+	if err := json.Unmarshal(rec.Body.Bytes(), &views); err != nil || len(views) != 1 || !views[0].HasAuthPassword || len(views[0].Devices) != 1 {
+		t.Fatalf("GET: %s (%v)", rec.Body.String(), err)
+	}
+
+	if rec := do(http.MethodDelete, "/api/snmp/profiles?id=1", ""); rec.Code != http.StatusNoContent {
+		t.Fatalf("DELETE: status %d", rec.Code)
+	}
+	remembered := map[string]int64{}
+	_ = store.GetConfigValue(snmpProfileMatchesKey, &remembered)
+	if len(remembered) != 0 {
+		t.Fatalf("matches of a deleted profile kept: %v", remembered)
+	}
+	if _, ok := scannerProfile(t, 1); ok {
+		t.Fatal("deleted profile still active")
+	}
+}
+
+// scannerProfile returns the active scanner profile with id, found through a
+// remembered match.
+func scannerProfile(t *testing.T, id int64) (scanner.CredentialProfile, bool) {
+	t.Helper()
+	scanner.LoadRememberedProfiles(map[string]int64{"10.9.0.5": id})
+	defer scanner.LoadRememberedProfiles(nil)
+	return scanner.RememberedProfile("10.9.0.5")
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"printmaster/agent/scanner"
+	"printmaster/agent/storage"
+	pmsettings "printmaster/common/settings"
+)
+
+// snmpSweepCommunitiesKey stores the communities found by community sweeps,
+// keyed by device IP.
+const snmpSweepCommunitiesKey = "snmp_sweep_communities"
+
+var (
+	snmpSweepMu      sync.Mutex
+	snmpSweepStore   storage.AgentConfigStore
+	snmpSweepEnabled bool
+)
+
+// initCommunitySweep restores remembered communities and starts recording
+// sweep events. Sweeping itself stays off until the server enables it.
+func initCommunitySweep(store storage.AgentConfigStore) {
+	snmpSweepMu.Lock()
+	snmpSweepStore = store
+	snmpSweepMu.Unlock()
+	if store != nil {
+		var remembered map[string]string
+		if err := store.GetConfigValue(snmpSweepCommunitiesKey, &remembered); err == nil && len(remembered) > 0 {
+			scanner.LoadRememberedCommunities(remembered)
+			if appLogger != nil {
+				appLogger.Info("Loaded SNMP communities from previous sweeps", "devices", len(remembered))
+			}
+		}
+	}
+	scanner.SetCommunitySweepObserver(recordCommunitySweepEvent)
+}
+
+// applySNMPSweepSettings configures the community sweep from fleet SNMP
+// settings. The sweep only runs when those settings come from the server, so
+// the candidate list cannot be supplied through local configuration.
+func applySNMPSweepSettings(snmp *pmsettings.SNMPSettings) {
+	if snmp == nil {
+		return
+	}
+	managed := settingsManager != nil && settingsManager.HasManagedSnapshot()
+	candidates := pmsettings.CommunityCandidateList(snmp.CommunityCandidates)
+	enabled := managed && snmp.CommunitySweepEnabled && len(candidates) > 0
+	scanner.SetCommunitySweep(scanner.CommunitySweepConfig{
+		Enabled:    enabled,
+		Candidates: candidates,
+		PerSecond:  snmp.CommunitySweepPerSecond,
+	})
+	snmpSweepMu.Lock()
+	changed := snmpSweepEnabled != enabled
+	snmpSweepEnabled = enabled
+	snmpSweepMu.Unlock()
+	if changed && appLogger != nil {
+		appLogger.Warn("SNMP community sweep setting changed", "enabled", enabled,
+			"candidates", len(candidates), "per_second", snmp.CommunitySweepPerSecond)
+	}
+}
+
+// recordCommunitySweepEvent logs every sweep attempt, remembers working
+// communities and reports sweep results to the server audit log. The
+// community itself is never logged or reported, only its list position.
+func recordCommunitySweepEvent(ev scanner.CommunitySweepEvent) {
+	if appLogger != nil {
+		appLogger.Info("SNMP community sweep", "ip", ev.IP, "outcome", ev.Outcome,
+			"candidate", ev.Index, "attempts", ev.Attempts)
+	}
+	if ev.Outcome == scanner.CommunitySweepAttemptFailed {
+		return
+	}
+	if ev.Outcome == scanner.CommunitySweepMatched {
+		rememberSweptCommunity(ev.IP, ev.Community)
+	}
+
+	uploadWorkerMu.RLock()
+	worker := uploadWorker
+	uploadWorkerMu.RUnlock()
+	client := worker.Client()
+	if client == nil {
+		return
+	}
+	details := map[string]interface{}{
+		"outcome":  ev.Outcome,
+		"attempts": ev.Attempts,
+	}
+	if ev.Index > 0 {
+		details["candidate"] = ev.Index
+	}
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+		defer cancel()
+		if err := client.LogAuditEvent(ctx, "snmp.community_sweep."+ev.Outcome, "device", ev.IP, details); err != nil && appLogger != nil {
+			appLogger.Warn("Failed to report SNMP community sweep", "ip", ev.IP, "error", err)
+		}
+	}()
+}
+
+func rememberSweptCommunity(ip, community string) {
+	snmpSweepMu.Lock()
+	defer snmpSweepMu.Unlock()
+	if snmpSweepStore == nil || ip == "" || community == "" {
+		return
+	}
+	remembered := map[string]string{}
+	_ = snmpSweepStore.GetConfigValue(snmpSweepCommunitiesKey, &remembered)
+	if remembered == nil {
+		remembered = map[string]string{}
+	}
+	remembered[ip] = community
+	if err := snmpSweepStore.SetConfigValue(snmpSweepCommunitiesKey, remembered); err != nil && appLogger != nil {
+		appLogger.Warn("Failed to save swept SNMP community", "ip", ip, "error", err)
+	}
+}
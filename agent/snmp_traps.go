@@ -0,0 +1,337 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gosnmp/gosnmp"
+
+	"printmaster/agent/agent"
+	"printmaster/agent/scanner"
+	"printmaster/agent/storage"
+)
+
+// maxRecentTraps bounds the in-memory list served by /api/v1/traps.
+const maxRecentTraps = 100
+
+// snmpTrapEvent is the SSE event sent for every received trap.
+const snmpTrapEvent = "snmp_trap"
+
+// prtMarkerSupplies columns walked when a supply trap arrives: description,
+// unit, max capacity and level.
+var supplyLevelOIDs = []string{
+	"1.3.6.1.2.1.43.11.1.1.6",
+	"1.3.6.1.2.1.43.11.1.1.7",
+	"1.3.6.1.2.1.43.11.1.1.8",
+	"1.3.6.1.2.1.43.11.1.1.9",
+}
+
+// TrapRecord is a received trap together with what the agent did about it.
+type TrapRecord struct {
+	agent.TrapEvent
+	Serial string `json:"serial,omitempty"`
+	Action string `json:"action,omitempty"` // supplies_collected, metrics_collected, discovery
+	Error  string `json:"error,omitempty"`
+}
+
+type trapLog struct {
+	mu      sync.Mutex
+	records []TrapRecord // newest last
+}
+
+var recentTraps = &trapLog{}
+
+// snmpTrapFallbackPort is the unprivileged port the trap listener binds
+// when 162 is unavailable; read each time the listener (re)starts.
+var snmpTrapFallbackPort atomic.Int32
+
+func init() {
+	snmpTrapFallbackPort.Store(agent.DefaultTrapFallbackPort)
+}
+
+// applySNMPTrapSettings updates the trap fallback port from a discovery
+// settings map. Missing or out-of-range values leave it untouched.
+func applySNMPTrapSettings(disc map[string]interface{}) {
+	if v, ok := disc["snmp_trap_fallback_port"].(float64); ok && v >= 1024 && v <= 65535 {
+		if old := snmpTrapFallbackPort.Swap(int32(v)); old != int32(v) && appLogger != nil {
+			appLogger.Info("SNMP trap fallback port changed; applies when the listener restarts", "port", int(v))
+		}
+	}
+}
+
+func trapFallbackPort() int {
+	return int(snmpTrapFallbackPort.Load())
+}
+
+func (l *trapLog) add(rec TrapRecord) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.records = append(l.records, rec)
+	if over := len(l.records) - maxRecentTraps; over > 0 {
+		l.records = append([]TrapRecord(nil), l.records[over:]...)
+	}
+}
+
+// list returns the recorded traps, newest first.
+func (l *trapLog) list() []TrapRecord {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	out := make([]TrapRecord, 0, len(l.records))
+	for i := len(l.records) - 1; i >= 0; i-- {
+		out = append(out, l.records[i])
+	}
+	return out
+}
+
+// snmpTrapHandler acts on received traps. timeoutSeconds and metricsEnabled
+// read the live agent settings.
+type snmpTrapHandler struct {
+	timeoutSeconds func() int
+	metricsEnabled func() bool
+}
+
+// handle is the trap listener callback. Supply and jam traps from a saved
+// device re-read just the affected values; anything else (and traps from
+// unknown devices) runs discovery for the sender as before.
+func (h *snmpTrapHandler) handle(ev agent.TrapEvent) bool {
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
+		defer cancel()
+		rec := TrapRecord{TrapEvent: ev}
+		if device := savedDeviceForTrap(ctx, ev); device != nil {
+			rec.Serial = device.Serial
+			rec.Action, rec.Error = h.collect(ctx, device, ev)
+		} else {
+			rec.Action = "discovery"
+			h.discover(ctx, ev.IP)
+		}
+		recordTrap(rec)
+	}()
+	return true
+}
+
+// savedDeviceForTrap returns the saved device at the trap's source address
+// when the trap calls for targeted collection, or nil.
+func savedDeviceForTrap(ctx context.Context, ev agent.TrapEvent) *storage.Device {
+	if !ev.Actionable() || deviceStore == nil {
+		return nil
+	}
+	saved := true
+	devices, err := deviceStore.List(ctx, storage.DeviceFilter{IP: ev.IP, IsSaved: &saved})
+	if err != nil || len(devices) == 0 {
+		return nil
+	}
+	return devices[0]
+}
+
+// collect re-reads the counters a trap affects and stores them as a
+// new metrics snapshot: supply levels for supply traps, the metrics profile
+// (which includes jam counters) for jam traps.
+func (h *snmpTrapHandler) collect(ctx context.Context, device *storage.Device, ev agent.TrapEvent) (string, string) {
+	var snapshot *storage.MetricsSnapshot
+	var action string
+	switch ev.Kind {
+	case agent.TrapKindSupplyLow, agent.TrapKindSupplyEmpty:
+		action = "supplies_collected"
+		levels, err := collectSupplyLevels(ctx, ev.IP, h.timeoutSeconds())
+		if err != nil {
+			return action, err.Error()
+		}
+		snapshot = &storage.MetricsSnapshot{}
+		if latest, err := deviceStore.GetLatestMetrics(ctx, device.Serial); err == nil && latest != nil {
+			// Counters were not re-read; carry them forward
+			*snapshot = *latest
+			snapshot.ID = 0
+		}
+		merged := make(map[string]interface{}, len(levels))
+		for k, v := range snapshot.TonerLevels {
+			merged[k] = v
+		}
+		for k, v := range levels {
+			merged[k] = v
+		}
+		snapshot.Serial = device.Serial
+		snapshot.TonerLevels = merged
+	default:
+		action = "metrics_collected"
+		pi := storage.DeviceToPrinterInfo(device)
+		agentSnapshot, err := CollectMetricsWithOIDs(ctx, ev.IP, device.Serial, device.Manufacturer, h.timeoutSeconds(), &pi.LearnedOIDs)
+		if err != nil {
+			return action, err.Error()
+		}
+		snapshot = &storage.MetricsSnapshot{}
+		snapshot.Serial = device.Serial
+		snapshot.PageCount = agentSnapshot.PageCount
+		snapshot.ColorPages = agentSnapshot.ColorPages
+		snapshot.MonoPages = agentSnapshot.MonoPages
+		snapshot.ScanCount = agentSnapshot.ScanCount
+		snapshot.TonerLevels = agentSnapshot.TonerLevels
+		snapshot.FaxPages = agentSnapshot.FaxPages
+		snapshot.CopyPages = agentSnapshot.CopyPages
+		snapshot.OtherPages = agentSnapshot.OtherPages
+		snapshot.CopyMonoPages = agentSnapshot.CopyMonoPages
+		snapshot.CopyFlatbedScans = agentSnapshot.CopyFlatbedScans
+		snapshot.CopyADFScans = agentSnapshot.CopyADFScans
+		snapshot.FaxFlatbedScans = agentSnapshot.FaxFlatbedScans
+		snapshot.FaxADFScans = agentSnapshot.FaxADFScans
+		snapshot.ScanToHostFlatbed = agentSnapshot.ScanToHostFlatbed
+		snapshot.ScanToHostADF = agentSnapshot.ScanToHostADF
+		snapshot.DuplexSheets = agentSnapshot.DuplexSheets
+		snapshot.SimplexSheets = agentSnapshot.SimplexSheets
+		snapshot.NUpSheets = agentSnapshot.NUpSheets
+		snapshot.JamEvents = agentSnapshot.JamEvents
+		snapshot.ScannerJamEvents = agentSnapshot.ScannerJamEvents
+	}
+
+	snapshot.Timestamp = time.Now()
+	if err := deviceStore.SaveMetricsSnapshot(ctx, snapshot); err != nil {
+		return action, err.Error()
+	}
+	appLogger.Info("SNMP Trap: targeted collection", "serial", device.Serial, "kind", ev.Kind, "action", action)
+	return action, ""
+}
+
+// collectSupplyLevels walks only the supply description, capacity and level
+// columns of the Printer-MIB supplies table.
+func collectSupplyLevels(ctx context.Context, ip string, timeoutSeconds int) (map[string]interface{}, error) {
+	cfg, err := scanner.GetSNMPConfig()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get SNMP config: %w", err)
+	}
+	client, err := scanner.NewSNMPClient(cfg, ip, timeoutSeconds)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create SNMP client: %w", err)
+	}
+	defer client.Close()
+
+	var pdus []gosnmp.SnmpPDU
+	for _, root := range supplyLevelOIDs {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		if err := client.Walk(root, func(pdu gosnmp.SnmpPDU) error {
+			pdus = append(pdus, pdu)
+			return nil
+		}); err != nil {
+			return nil, fmt.Errorf("walk %s: %w", root, err)
+		}
+	}
+	if len(pdus) == 0 {
+		return nil, fmt.Errorf("no supply data received from %s", ip)
+	}
+	pi, _ := agent.ParsePDUs(ip, pdus, &agent.ScanMeta{DiscoveryMethods: []string{"snmp_trap"}}, func(string) {})
+	levels := tonerLevelsFromPrinterInfo(pi)
+	if len(levels) == 0 {
+		return nil, fmt.Errorf("no toner levels reported by %s", ip)
+	}
+	return levels, nil
+}
+
+// discover enriches the trap sender like any live discovery and, for
+// saved devices, collects a full metrics snapshot.
+func (h *snmpTrapHandler) discover(ctx context.Context, ip string) {
+	pi, err := LiveDiscoveryDetect(ctx, ip, h.timeoutSeconds())
+	if err != nil {
+		appLogger.WarnRateLimited("trap_enrich_"+ip, 5*time.Minute, "SNMP Trap: enrichment failed", "ip", ip, "error", err)
+		return
+	}
+
+	serial := pi.Serial
+	if serial == "" {
+		appLogger.Debug("SNMP Trap: no serial found for device", "ip", ip)
+		return
+	}
+
+	// Check if device exists in DB
+	existing, err := deviceStore.Get(ctx, serial)
+	if err == nil && existing != nil {
+		// Known device - update LastSeen
+		existing.LastSeen = time.Now()
+		existing.IP = ip
+		if updateErr := deviceStore.Update(ctx, existing); updateErr == nil {
+			appLogger.Debug("SNMP Trap: known device updated", "ip", ip, "serial", serial)
+		}
+	} else {
+		// New device
+		appLogger.Debug("SNMP Trap: new device discovered", "ip", ip, "serial", serial)
+	}
+
+	// Store/update the device
+	agent.UpsertDiscoveredPrinter(*pi)
+	appLogger.Info("SNMP Trap: discovered device", "ip", ip, "serial", serial)
+
+	// If metrics monitoring is enabled and device is saved, collect metrics immediately
+	if !h.metricsEnabled() || deviceStore == nil {
+		return
+	}
+	device, err := deviceStore.Get(ctx, serial)
+	if err != nil || device == nil || !device.IsSaved {
+		return
+	}
+	if _, errMsg := h.collect(ctx, device, agent.TrapEvent{IP: ip, Kind: agent.TrapKindOther}); errMsg != "" {
+		appLogger.WarnRateLimited("trap_metrics_"+serial, 5*time.Minute, "SNMP Trap: metrics collection failed", "serial", serial, "error", errMsg)
+	}
+}
+
+// recordTrap keeps the trap for /api/v1/traps, writes it to the scan event
+// log and broadcasts it to the UI.
+func recordTrap(rec TrapRecord) {
+	recentTraps.add(rec)
+	msg := fmt.Sprintf("SNMP TRAP: %s from %s (kind=%s", rec.Type, rec.IP, rec.Kind)
+	if rec.Serial != "" {
+		msg += " serial=" + rec.Serial
+	}
+	if rec.Description != "" {
+		msg += fmt.Sprintf(" alert=%q", rec.Description)
+	}
+	msg += " action=" + rec.Action + ")"
+	agent.AppendScanEvent(msg)
+
+	if sseHub != nil {
+		data := map[string]interface{}{
+			"ip":          rec.IP,
+			"serial":      rec.Serial,
+			"trap_oid":    rec.TrapOID,
+			"kind":        rec.Kind,
+			"severity":    rec.Severity,
+			"group":       rec.Group,
+			"group_index": rec.GroupIndex,
+			"code":        rec.Code,
+			"description": rec.Description,
+			"action":      rec.Action,
+			"received_at": rec.ReceivedAt,
+		}
+		if rec.Error != "" {
+			data["error"] = rec.Error
+		}
+		sseHub.Broadcast(SSEEvent{Type: snmpTrapEvent, Data: data})
+	}
+}
+
+// handleTrapStatus serves GET /api/v1/traps/status: whether the trap
+// listener is enabled and actually receiving, which port it bound and, when
+// it had to fall back, how to get traps sent to 162 delivered.
+func handleTrapStatus(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "GET only", http.StatusMethodNotAllowed)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(agent.TrapListenerState())
+}
+
+// handleRecentTraps serves GET /api/v1/traps: the last received traps,
+// newest first.
+func handleRecentTraps(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "GET only", http.StatusMethodNotAllowed)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(recentTraps.list())
+}
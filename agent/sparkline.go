@@ -0,0 +1,208 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"printmaster/agent/agent"
+	"printmaster/agent/storage"
+)
+
+const (
+	sparklineDefaultDays = 30
+	sparklineMaxDays     = 90
+)
+
+// deviceSparkline is the compact, pre-bucketed series used by device cards.
+// Each slice has one entry per local calendar day from Start to End.
+type deviceSparkline struct {
+	Serial          string            `json:"serial"`
+	Days            int               `json:"days"`
+	Start           string            `json:"start"`
+	End             string            `json:"end"`
+	Pages           []int             `json:"pages"`
+	TotalPages      int               `json:"total_pages"`
+	LatestPageCount int               `json:"latest_page_count"`
+	Toner           map[string][]*int `json:"toner,omitempty"`
+	GeneratedAt     time.Time         `json:"generated_at"`
+}
+
+// buildSparkline buckets snapshots into pages printed per day and the last
+// toner level seen each day. Snapshots before the first day only provide
+// the baseline page count; days without data have 0 pages and null toner.
+func buildSparkline(serial string, snapshots []*storage.MetricsSnapshot, days int, now time.Time) *deviceSparkline {
+	loc := now.Location()
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, loc)
+	first := today.AddDate(0, 0, -(days - 1))
+
+	sorted := make([]*storage.MetricsSnapshot, 0, len(snapshots))
+	for _, snap := range snapshots {
+		if snap != nil {
+			sorted = append(sorted, snap)
+		}
+	}
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].Timestamp.Before(sorted[j].Timestamp)
+	})
+
+	dayMin := make([]int, days)
+	dayMax := make([]int, days)
+	seen := make([]bool, days)
+	baseline, hasBaseline := 0, false
+	toner := make(map[string][]*int)
+
+	result := &deviceSparkline{
+		Serial:      serial,
+		Days:        days,
+		Start:       first.Format("2006-01-02"),
+		End:         today.Format("2006-01-02"),
+		Pages:       make([]int, days),
+		GeneratedAt: now.UTC(),
+	}
+
+	for _, snap := range sorted {
+		ts := snap.Timestamp.In(loc)
+		if ts.Before(first) {
+			if snap.PageCount > 0 {
+				baseline, hasBaseline = snap.PageCount, true
+			}
+			continue
+		}
+		idx := dayIndex(first, ts)
+		if idx < 0 || idx >= days {
+			continue
+		}
+		if snap.PageCount > 0 {
+			if !seen[idx] {
+				dayMin[idx], dayMax[idx], seen[idx] = snap.PageCount, snap.PageCount, true
+			} else {
+				dayMin[idx] = min(dayMin[idx], snap.PageCount)
+				dayMax[idx] = max(dayMax[idx], snap.PageCount)
+			}
+			result.LatestPageCount = snap.PageCount
+		}
+		for key, raw := range snap.TonerLevels {
+			level, ok := sparklineLevel(raw)
+			if !ok {
+				continue
+			}
+			series, exists := toner[key]
+			if !exists {
+				series = make([]*int, days)
+				toner[key] = series
+			}
+			series[idx] = &level
+		}
+	}
+
+	prev, hasPrev := baseline, hasBaseline
+	for i := 0; i < days; i++ {
+		if !seen[i] {
+			continue
+		}
+		delta := dayMax[i] - dayMin[i]
+		if hasPrev {
+			delta = dayMax[i] - prev
+		}
+		// Counter resets (device replaced or NVRAM cleared) count as no pages
+		if delta < 0 {
+			delta = 0
+		}
+		result.Pages[i] = delta
+		result.TotalPages += delta
+		prev, hasPrev = dayMax[i], true
+	}
+	if result.LatestPageCount == 0 && hasBaseline {
+		result.LatestPageCount = baseline
+	}
+	if len(toner) > 0 {
+		result.Toner = toner
+	}
+	return result
+}
+
+// dayIndex returns how many calendar days ts is after first, counting days
+// rather than 24h periods so DST changes don't shift buckets.
+func dayIndex(first, ts time.Time) int {
+	day := time.Date(ts.Year(), ts.Month(), ts.Day(), 0, 0, 0, 0, first.Location())
+	return int(math.Round(day.Sub(first).Hours() / 24))
+}
+
+// sparklineLevel converts a stored toner level to a whole percentage.
+func sparklineLevel(v interface{}) (int, bool) {
+	var f float64
+	switch n := v.(type) {
+	case float64:
+		f = n
+	case float32:
+		f = float64(n)
+	case int:
+		f = float64(n)
+	case int64:
+		f = float64(n)
+	case json.Number:
+		parsed, err := n.Float64()
+		if err != nil {
+			return 0, false
+		}
+		f = parsed
+	default:
+		return 0, false
+	}
+	// Negative values are SNMP sentinels (unknown / some remaining)
+	if f < 0 || math.IsNaN(f) {
+		return 0, false
+	}
+	return int(math.Round(f)), true
+}
+
+// registerSparklineHandlers exposes compact per-device trend series for the
+// dashboard cards.
+func registerSparklineHandlers() {
+	// GET /api/v1/devices/{serial}/sparkline?days=30
+	http.HandleFunc("/api/v1/devices/", func(w http.ResponseWriter, r *http.Request) {
+		rest := strings.TrimPrefix(r.URL.Path, "/api/v1/devices/")
+		serial, ok := strings.CutSuffix(rest, "/sparkline")
+		if !ok || serial == "" || strings.Contains(serial, "/") {
+			http.NotFound(w, r)
+			return
+		}
+		if r.Method != http.MethodGet {
+			http.Error(w, "GET only", http.StatusMethodNotAllowed)
+			return
+		}
+
+		days := sparklineDefaultDays
+		if d := r.URL.Query().Get("days"); d != "" {
+			parsed, err := strconv.Atoi(d)
+			if err != nil || parsed <= 0 {
+				http.Error(w, "invalid days parameter", http.StatusBadRequest)
+				return
+			}
+			days = min(parsed, sparklineMaxDays)
+		}
+
+		now := time.Now()
+		// Start one day early so the first bucket has a baseline to diff against
+		since := now.AddDate(0, 0, -days)
+
+		ctx, cancel := context.WithTimeout(r.Context(), 20*time.Second)
+		defer cancel()
+		snapshots, err := deviceStore.GetTieredMetricsHistory(ctx, serial, since, now)
+		if err != nil {
+			agent.Error(fmt.Sprintf("Failed to get sparkline metrics: serial=%s error=%v", serial, err))
+			http.Error(w, "failed to get metrics history: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(buildSparkline(serial, snapshots, days, now))
+	})
+}
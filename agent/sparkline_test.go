@@ -0,0 +1,73 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"printmaster/agent/storage"
+	commonstorage "printmaster/common/storage"
+)
+
+func sparkSnap(ts time.Time, pages int, toner map[string]interface{}) *storage.MetricsSnapshot {
+	return &storage.MetricsSnapshot{MetricsSnapshot: commonstorage.MetricsSnapshot{
+		Serial:      "SN1",
+		Timestamp:   ts,
+		PageCount:   pages,
+		TonerLevels: toner,
+	}}
+}
+
+func TestBuildSparkline(t *testing.T) {
+	t.Parallel()
+	loc := time.FixedZone("test", -5*3600)
+	now := time.Date(2026, 3, 10, 15, 0, 0, 0, loc)
+	day := func(offset, hour int) time.Time {
+		return time.Date(2026, 3, 10+offset, hour, 0, 0, 0, loc)
+	}
+
+	snapshots := []*storage.MetricsSnapshot{
+		// Out of order on purpose; tiers are merged before bucketing
+		sparkSnap(day(0, 9), 1180, map[string]interface{}{"black": 40.0}),
+		sparkSnap(day(-3, 9), 1000, nil), // baseline before the window
+		sparkSnap(day(-2, 8), 1050, map[string]interface{}{"black": 55.0, "cyan": -2.0}),
+		sparkSnap(day(-2, 17), 1100, nil),
+		sparkSnap(day(0, 12), 1200, map[string]interface{}{"black": 38.4}),
+	}
+
+	got := buildSparkline("SN1", snapshots, 3, now)
+	if got.Start != "2026-03-08" || got.End != "2026-03-10" {
+		t.Fatalf("unexpected window: %s..%s", got.Start, got.End)
+	}
+	if len(got.Pages) != 3 || got.Pages[0] != 100 || got.Pages[1] != 0 || got.Pages[2] != 100 {
+		t.Fatalf("unexpected pages: %v", got.Pages)
+	}
+	if got.TotalPages != 200 || got.LatestPageCount != 1200 {
+		t.Fatalf("unexpected totals: total=%d latest=%d", got.TotalPages, got.LatestPageCount)
+	}
+
+	black := got.Toner["black"]
+	if len(black) != 3 || black[0] == nil || *black[0] != 55 || black[1] != nil || black[2] == nil || *black[2] != 38 {
+		t.Fatalf("unexpected black toner series: %v", black)
+	}
+	if _, ok := got.Toner["cyan"]; ok {
+		t.Fatalf("sentinel toner values should be dropped: %v", got.Toner)
+	}
+}
+
+func TestBuildSparkline_CounterResetAndNoBaseline(t *testing.T) {
+	t.Parallel()
+	now := time.Date(2026, 3, 10, 15, 0, 0, 0, time.UTC)
+	snapshots := []*storage.MetricsSnapshot{
+		sparkSnap(now.AddDate(0, 0, -1).Add(-6*time.Hour), 500, nil),
+		sparkSnap(now.AddDate(0, 0, -1), 540, nil),
+		sparkSnap(now, 20, nil), // counter reset
+	}
+
+	got := buildSparkline("SN1", snapshots, 2, now)
+	if got.Pages[0] != 40 || got.Pages[1] != 0 || got.TotalPages != 40 {
+		t.Fatalf("unexpected pages: %v total=%d", got.Pages, got.TotalPages)
+	}
+	if got.Toner != nil {
+		t.Fatalf("expected no toner series, got %v", got.Toner)
+	}
+}
@@ -0,0 +1,337 @@
+package main
+
+import (
+	"container/list"
+	"context"
+	"encoding/gob"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"printmaster/agent/devicetls"
+)
+
+// Printers serve their web UI assets painfully slowly, so the proxy keeps
+// static resources in a size-capped LRU cache. Entries are fresh for
+// staticCacheTTL; after that they are served stale for up to
+// staticCacheStaleWindow while a conditional request (If-Modified-Since /
+// If-None-Match) checks the device in the background. The cache is saved to
+// the data directory so it survives agent restarts.
+
+const (
+	staticCacheTTL          = 15 * time.Minute
+	staticCacheStaleWindow  = 7 * 24 * time.Hour
+	staticCacheMaxBytes     = 64 << 20
+	staticCacheMaxItems     = 4000
+	staticCacheFileName     = "proxy_static_cache.gob"
+	staticCacheSaveInterval = 10 * time.Minute
+)
+
+// Cache lookup results.
+type staticCacheState int
+
+const (
+	staticCacheMiss  staticCacheState = iota
+	staticCacheFresh                  // within TTL
+	staticCacheStale                  // expired but servable while revalidating
+)
+
+type staticResourceCache struct {
+	sync.Mutex
+	items      map[string]*list.Element
+	lru        *list.List // front = most recently used
+	totalBytes int64
+	maxBytes   int64
+	maxItems   int
+	dirty      bool
+
+	// revalidate performs a conditional request; replaced in tests
+	revalidate func(ctx context.Context, res *cachedResource) (notModified bool, err error)
+}
+
+type cachedResource struct {
+	Key          string
+	Data         []byte
+	ContentType  string
+	Headers      http.Header
+	Expiry       time.Time
+	LastModified string
+	ETag         string
+	// Origin is the device URL the resource came from; empty when it cannot
+	// be revalidated directly (USB devices), so it simply expires.
+	Origin string
+
+	revalidating bool
+}
+
+func newStaticResourceCache() *staticResourceCache {
+	c := &staticResourceCache{
+		items:    make(map[string]*list.Element),
+		lru:      list.New(),
+		maxBytes: staticCacheMaxBytes,
+		maxItems: staticCacheMaxItems,
+	}
+	c.revalidate = c.conditionalGet
+	return c
+}
+
+// Lookup returns the cached resource for key and whether it is fresh or
+// stale. Stale hits start a background revalidation against the device.
+func (c *staticResourceCache) Lookup(key string) (cachedResource, staticCacheState) {
+	c.Lock()
+	defer c.Unlock()
+	el, ok := c.items[key]
+	if !ok {
+		return cachedResource{}, staticCacheMiss
+	}
+	res := el.Value.(*cachedResource)
+	now := time.Now()
+	if now.Before(res.Expiry) {
+		c.lru.MoveToFront(el)
+		return *res, staticCacheFresh
+	}
+	if res.Origin == "" || now.After(res.Expiry.Add(staticCacheStaleWindow)) {
+		c.removeElement(el)
+		return cachedResource{}, staticCacheMiss
+	}
+	c.lru.MoveToFront(el)
+	if !res.revalidating {
+		res.revalidating = true
+		go c.revalidateEntry(key, *res)
+	}
+	return *res, staticCacheStale
+}
+
+// Set caches a resource fetched from origin, evicting the least recently
+// used entries to stay within the size caps.
+func (c *staticResourceCache) Set(key string, data []byte, contentType string, headers http.Header, origin string, ttl time.Duration) {
+	size := int64(len(data))
+	c.Lock()
+	defer c.Unlock()
+	if size > c.maxBytes/4 {
+		// One oversized asset must not flush the whole cache
+		return
+	}
+	if el, ok := c.items[key]; ok {
+		c.removeElement(el)
+	}
+	res := &cachedResource{
+		Key:          key,
+		Data:         data,
+		ContentType:  contentType,
+		Headers:      headers,
+		Expiry:       time.Now().Add(ttl),
+		LastModified: headers.Get("Last-Modified"),
+		ETag:         headers.Get("ETag"),
+		Origin:       origin,
+	}
+	c.items[key] = c.lru.PushFront(res)
+	c.totalBytes += size
+	c.dirty = true
+	c.evictLocked()
+}
+
+// Len reports the number of entries and their total size.
+func (c *staticResourceCache) Len() (int, int64) {
+	c.Lock()
+	defer c.Unlock()
+	return len(c.items), c.totalBytes
+}
+
+func (c *staticResourceCache) evictLocked() {
+	for (c.totalBytes > c.maxBytes || len(c.items) > c.maxItems) && c.lru.Len() > 0 {
+		c.removeElement(c.lru.Back())
+	}
+}
+
+func (c *staticResourceCache) removeElement(el *list.Element) {
+	res := el.Value.(*cachedResource)
+	c.lru.Remove(el)
+	delete(c.items, res.Key)
+	c.totalBytes -= int64(len(res.Data))
+	c.dirty = true
+}
+
+// revalidateEntry asks the device whether a stale resource changed. Not
+// modified extends its lifetime; a changed resource is dropped so the next
+// request goes through the proxy (which rewrites the body) and re-caches it.
+// Errors keep serving the stale copy until the stale window ends.
+func (c *staticResourceCache) revalidateEntry(key string, res cachedResource) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	notModified, err := c.revalidate(ctx, &res)
+
+	c.Lock()
+	defer c.Unlock()
+	el, ok := c.items[key]
+	if !ok {
+		return
+	}
+	cur := el.Value.(*cachedResource)
+	cur.revalidating = false
+	if cur.Expiry != res.Expiry {
+		// Replaced while we were checking
+		return
+	}
+	switch {
+	case err != nil:
+		if appLogger != nil {
+			appLogger.Debug("Proxy cache: revalidation failed, serving stale", "key", key, "error", err)
+		}
+	case notModified:
+		cur.Expiry = time.Now().Add(staticCacheTTL)
+		c.dirty = true
+	default:
+		c.removeElement(el)
+	}
+}
+
+var staticCacheClient = &http.Client{
+	Timeout: 30 * time.Second,
+	Transport: devicetls.Transport(&http.Transport{
+		ResponseHeaderTimeout: 20 * time.Second,
+		DialContext:           (&net.Dialer{Timeout: 10 * time.Second}).DialContext,
+	}),
+	// A redirect usually means a login page; treat it as changed
+	CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse },
+}
+
+// conditionalGet revalidates res against its origin. Devices that send no
+// validators are compared by content instead.
+func (c *staticResourceCache) conditionalGet(ctx context.Context, res *cachedResource) (bool, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, res.Origin, nil)
+	if err != nil {
+		return false, err
+	}
+	if res.LastModified != "" {
+		req.Header.Set("If-Modified-Since", res.LastModified)
+	}
+	if res.ETag != "" {
+		req.Header.Set("If-None-Match", res.ETag)
+	}
+	resp, err := staticCacheClient.Do(req)
+	if err != nil {
+		return false, err
+	}
+	defer resp.Body.Close()
+	switch {
+	case resp.StatusCode == http.StatusNotModified:
+		return true, nil
+	case resp.StatusCode != http.StatusOK:
+		_, _ = io.Copy(io.Discard, resp.Body)
+		return false, fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+	if res.LastModified != "" && resp.Header.Get("Last-Modified") == res.LastModified {
+		return true, nil
+	}
+	if res.ETag != "" && resp.Header.Get("ETag") == res.ETag {
+		return true, nil
+	}
+	// Unchanged bodies that the proxy did not rewrite compare equal
+	body, err := io.ReadAll(io.LimitReader(resp.Body, int64(len(res.Data))+1))
+	if err != nil {
+		return false, err
+	}
+	return string(body) == string(res.Data), nil
+}
+
+// Save writes the cache to path, least recently used first, replacing the
+// file atomically. It is a no-op when nothing changed since the last save.
+func (c *staticResourceCache) Save(path string) error {
+	c.Lock()
+	if !c.dirty {
+		c.Unlock()
+		return nil
+	}
+	entries := make([]cachedResource, 0, len(c.items))
+	for el := c.lru.Back(); el != nil; el = el.Prev() {
+		entries = append(entries, *el.Value.(*cachedResource))
+	}
+	c.dirty = false
+	c.Unlock()
+
+	tmp := path + ".tmp"
+	f, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	if err := gob.NewEncoder(f).Encode(entries); err != nil {
+		f.Close()
+		os.Remove(tmp)
+		return err
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// Load restores a cache saved by Save, skipping entries past their stale
+// window. A missing file is not an error.
+func (c *staticResourceCache) Load(path string) error {
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	var entries []cachedResource
+	if err := gob.NewDecoder(f).Decode(&entries); err != nil {
+		return fmt.Errorf("decode proxy cache: %w", err)
+	}
+
+	now := time.Now()
+	c.Lock()
+	defer c.Unlock()
+	for i := range entries {
+		res := entries[i]
+		if now.After(res.Expiry.Add(staticCacheStaleWindow)) || (res.Origin == "" && now.After(res.Expiry)) {
+			continue
+		}
+		if el, ok := c.items[res.Key]; ok {
+			c.removeElement(el)
+		}
+		c.items[res.Key] = c.lru.PushFront(&res)
+		c.totalBytes += int64(len(res.Data))
+	}
+	c.evictLocked()
+	return nil
+}
+
+// startStaticCachePersistence loads the saved proxy cache from dataDir and
+// saves it periodically until ctx ends. Call saveStaticCache on shutdown.
+func startStaticCachePersistence(ctx context.Context, dataDir string) {
+	path := filepath.Join(dataDir, staticCacheFileName)
+	if err := staticCache.Load(path); err != nil {
+		appLogger.Warn("Proxy cache: failed to load saved cache", "path", path, "error", err)
+	} else if n, size := staticCache.Len(); n > 0 {
+		appLogger.Info("Proxy cache: restored static resources", "count", n, "bytes", size)
+	}
+	go func() {
+		ticker := time.NewTicker(staticCacheSaveInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				saveStaticCache(dataDir)
+			}
+		}
+	}()
+}
+
+func saveStaticCache(dataDir string) {
+	path := filepath.Join(dataDir, staticCacheFileName)
+	if err := staticCache.Save(path); err != nil {
+		appLogger.Warn("Proxy cache: failed to save cache", "path", path, "error", err)
+	}
+}
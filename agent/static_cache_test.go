@@ -0,0 +1,140 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestStaticCacheLRUEviction(t *testing.T) {
+	c := newStaticResourceCache()
+	c.maxBytes = 40
+	c.maxItems = 3
+
+	c.Set("a", make([]byte, 10), "text/css", http.Header{}, "", time.Minute)
+	c.Set("b", make([]byte, 10), "text/css", http.Header{}, "", time.Minute)
+	c.Set("c", make([]byte, 10), "text/css", http.Header{}, "", time.Minute)
+	// Touch a so b becomes least recently used
+	if _, state := c.Lookup("a"); state != staticCacheFresh {
+		t.Fatalf("a state = %v", state)
+	}
+	c.Set("d", make([]byte, 10), "text/css", http.Header{}, "", time.Minute)
+	if _, state := c.Lookup("b"); state != staticCacheMiss {
+		t.Error("expected b to be evicted by the item cap")
+	}
+
+	c.maxItems = 10
+	c.Set("e", make([]byte, 10), "text/css", http.Header{}, "", time.Minute)
+	c.Set("f", make([]byte, 10), "text/css", http.Header{}, "", time.Minute)
+	if n, size := c.Len(); size > 40 || n != 4 {
+		t.Errorf("cache holds %d entries, %d bytes; want 4 within 40 bytes", n, size)
+	}
+	// Oversized resources are not cached
+	c.Set("huge", make([]byte, 30), "image/png", http.Header{}, "", time.Minute)
+	if _, state := c.Lookup("huge"); state != staticCacheMiss {
+		t.Error("oversized resource was cached")
+	}
+}
+
+func TestStaticCacheStaleWhileRevalidate(t *testing.T) {
+	c := newStaticResourceCache()
+	checked := make(chan struct{}, 1)
+	notModified := true
+	c.revalidate = func(ctx context.Context, res *cachedResource) (bool, error) {
+		defer func() { checked <- struct{}{} }()
+		return notModified, nil
+	}
+	waitRevalidate := func() {
+		t.Helper()
+		select {
+		case <-checked:
+		case <-time.After(2 * time.Second):
+			t.Fatal("revalidation did not run")
+		}
+		// Let revalidateEntry apply the result
+		time.Sleep(20 * time.Millisecond)
+	}
+
+	c.Set("k", []byte("body"), "text/css", http.Header{}, "http://printer/app.css", -time.Second)
+	res, state := c.Lookup("k")
+	if state != staticCacheStale || string(res.Data) != "body" {
+		t.Fatalf("expected stale hit, got %v", state)
+	}
+	waitRevalidate()
+	if _, state := c.Lookup("k"); state != staticCacheFresh {
+		t.Errorf("not-modified revalidation should refresh the entry, got %v", state)
+	}
+
+	notModified = false
+	c.Set("k", []byte("body"), "text/css", http.Header{}, "http://printer/app.css", -time.Second)
+	c.Lookup("k")
+	waitRevalidate()
+	if _, state := c.Lookup("k"); state != staticCacheMiss {
+		t.Errorf("changed resource should be dropped, got %v", state)
+	}
+
+	// Without an origin there is nothing to revalidate against
+	c.Set("usb", []byte("body"), "text/css", http.Header{}, "", -time.Second)
+	if _, state := c.Lookup("usb"); state != staticCacheMiss {
+		t.Errorf("expired USB resource state = %v, want miss", state)
+	}
+}
+
+func TestStaticCacheConditionalGet(t *testing.T) {
+	const lastModified = "Mon, 02 Jan 2006 15:04:05 GMT"
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("If-Modified-Since") == lastModified {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Write([]byte("new body"))
+	}))
+	defer srv.Close()
+
+	c := newStaticResourceCache()
+	ctx := context.Background()
+	notModified, err := c.conditionalGet(ctx, &cachedResource{Origin: srv.URL, LastModified: lastModified, Data: []byte("old")})
+	if err != nil || !notModified {
+		t.Errorf("If-Modified-Since: notModified=%v err=%v", notModified, err)
+	}
+	notModified, err = c.conditionalGet(ctx, &cachedResource{Origin: srv.URL, Data: []byte("old body")})
+	if err != nil || notModified {
+		t.Errorf("changed body: notModified=%v err=%v", notModified, err)
+	}
+	notModified, _ = c.conditionalGet(ctx, &cachedResource{Origin: srv.URL, Data: []byte("new body")})
+	if !notModified {
+		t.Error("identical body without validators should count as not modified")
+	}
+}
+
+func TestStaticCachePersistence(t *testing.T) {
+	path := filepath.Join(t.TempDir(), staticCacheFileName)
+	c := newStaticResourceCache()
+	c.Set("old", []byte("1"), "text/css", http.Header{"Etag": {`"v1"`}}, "http://printer/old.css", time.Minute)
+	c.Set("new", []byte("2"), "text/css", http.Header{}, "http://printer/new.css", time.Minute)
+	c.Set("gone", []byte("3"), "text/css", http.Header{}, "", -time.Minute)
+	if err := c.Save(path); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+
+	restored := newStaticResourceCache()
+	if err := restored.Load(path); err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	res, state := restored.Lookup("old")
+	if state != staticCacheFresh || string(res.Data) != "1" || res.ETag != `"v1"` {
+		t.Errorf("restored entry = %+v (%v)", res, state)
+	}
+	if n, _ := restored.Len(); n != 2 {
+		t.Errorf("restored %d entries, want 2 (expired USB entry skipped)", n)
+	}
+	// LRU order survives: "old" was touched last, so "new" goes first
+	restored.maxItems = 2
+	restored.Set("x", []byte("4"), "text/css", http.Header{}, "", time.Minute)
+	if _, state := restored.Lookup("new"); state != staticCacheMiss {
+		t.Error("expected least recently used entry to be evicted after restore")
+	}
+}
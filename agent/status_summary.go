@@ -0,0 +1,284 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"printmaster/agent/agent"
+	"printmaster/agent/featureflags"
+	"printmaster/agent/spooler"
+	pmsettings "printmaster/common/settings"
+)
+
+// Subsystem states reported by /api/v1/status/summary. Only disabled,
+// degraded and down put the UI into degraded mode; off means the subsystem
+// is simply not in use (not configured, unsupported or turned off locally).
+const (
+	subsystemOK       = "ok"
+	subsystemOff      = "off"
+	subsystemDisabled = "disabled" // Turned off by server policy
+	subsystemDegraded = "degraded"
+	subsystemDown     = "down"
+)
+
+const (
+	statusMonitorInterval = 30 * time.Second
+	// statusProbeKey is rewritten on every check to prove the database
+	// still accepts writes.
+	statusProbeKey = "status_probe"
+	// statusChangedEvent is the SSE event sent when a subsystem changes state.
+	statusChangedEvent = "status_changed"
+
+	// EventSource reconnect delays: back off while the agent is degraded so
+	// reconnecting UIs do not add load to a struggling agent.
+	sseRetryHealthy  = 3 * time.Second
+	sseRetryDegraded = 15 * time.Second
+)
+
+// SubsystemStatus is the state of one agent subsystem.
+type SubsystemStatus struct {
+	Name   string    `json:"name"`
+	State  string    `json:"state"`
+	Reason string    `json:"reason,omitempty"`
+	Since  time.Time `json:"since"`
+}
+
+// StatusSummary enumerates subsystem states for the degraded-mode banner.
+type StatusSummary struct {
+	Overall    string            `json:"overall"` // ok or degraded
+	Subsystems []SubsystemStatus `json:"subsystems"`
+	CheckedAt  time.Time         `json:"checked_at"`
+	RetryMs    int64             `json:"retry_ms"`
+}
+
+type statusTracker struct {
+	mu       sync.Mutex
+	dbErr    error
+	dbProbed bool
+	last     map[string]SubsystemStatus
+}
+
+var agentStatus = &statusTracker{last: make(map[string]SubsystemStatus)}
+
+// runStatusMonitor probes the database and re-evaluates subsystem states
+// until ctx is done, broadcasting every transition over SSE.
+func runStatusMonitor(ctx context.Context) {
+	ticker := time.NewTicker(statusMonitorInterval)
+	defer ticker.Stop()
+	for {
+		agentStatus.probeDatabase()
+		agentStatus.refresh()
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (t *statusTracker) probeDatabase() {
+	var err error
+	if agentConfigStore != nil {
+		err = agentConfigStore.SetConfigValue(statusProbeKey, time.Now().UTC())
+	}
+	t.mu.Lock()
+	t.dbErr, t.dbProbed = err, agentConfigStore != nil
+	t.mu.Unlock()
+}
+
+// refresh evaluates every subsystem, records state changes and broadcasts
+// them, and returns the current summary.
+func (t *statusTracker) refresh() StatusSummary {
+	t.mu.Lock()
+	dbErr, dbProbed := t.dbErr, t.dbProbed
+	t.mu.Unlock()
+
+	uploadWorkerMu.RLock()
+	worker := uploadWorker
+	uploadWorkerMu.RUnlock()
+	spoolerWorkerMu.RLock()
+	spoolerRunning := spoolerWorker != nil && spoolerWorker.IsRunning()
+	spoolerWorkerMu.RUnlock()
+	cfg := loadUnifiedSettings(agentConfigStore)
+
+	current := []SubsystemStatus{
+		databaseStatus(dbProbed, dbErr),
+		scannerStatus(cfg, settingsManager.HasManagedSnapshot()),
+		uploadStatus(worker),
+		spoolerStatus(cfg.Spooler.Enabled, spooler.IsSupported(), spoolerRunning),
+		proxyStatus(worker, featureflags.RemoteProxyEnabled()),
+	}
+	return t.record(current, time.Now().UTC())
+}
+
+func (t *statusTracker) record(current []SubsystemStatus, now time.Time) StatusSummary {
+	type transition struct {
+		status   SubsystemStatus
+		previous string
+	}
+	var changes []transition
+
+	t.mu.Lock()
+	for i, s := range current {
+		prev, seen := t.last[s.Name]
+		switch {
+		case !seen:
+			s.Since = now
+		case prev.State != s.State:
+			s.Since = now
+			changes = append(changes, transition{status: s, previous: prev.State})
+		default:
+			s.Since = prev.Since
+		}
+		t.last[s.Name] = s
+		current[i] = s
+	}
+	t.mu.Unlock()
+
+	summary := StatusSummary{Overall: overallState(current), Subsystems: current, CheckedAt: now}
+	summary.RetryMs = sseRetryHint(summary.Overall).Milliseconds()
+	for _, c := range changes {
+		if appLogger != nil {
+			if isDegradedState(c.status.State) {
+				appLogger.Warn("Subsystem degraded", "subsystem", c.status.Name, "state", c.status.State, "previous", c.previous, "reason", c.status.Reason)
+			} else {
+				appLogger.Info("Subsystem state changed", "subsystem", c.status.Name, "state", c.status.State, "previous", c.previous)
+			}
+		}
+		if sseHub != nil {
+			sseHub.Broadcast(SSEEvent{
+				Type: statusChangedEvent,
+				Data: map[string]interface{}{
+					"subsystem": c.status.Name,
+					"state":     c.status.State,
+					"previous":  c.previous,
+					"reason":    c.status.Reason,
+					"overall":   summary.Overall,
+					"retry_ms":  summary.RetryMs,
+				},
+			})
+		}
+	}
+	return summary
+}
+
+// overall returns the last recorded overall state without re-evaluating.
+func (t *statusTracker) overall() string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	states := make([]SubsystemStatus, 0, len(t.last))
+	for _, s := range t.last {
+		states = append(states, s)
+	}
+	return overallState(states)
+}
+
+func isDegradedState(state string) bool {
+	return state == subsystemDisabled || state == subsystemDegraded || state == subsystemDown
+}
+
+func overallState(states []SubsystemStatus) string {
+	for _, s := range states {
+		if isDegradedState(s.State) {
+			return "degraded"
+		}
+	}
+	return "ok"
+}
+
+func sseRetryHint(overall string) time.Duration {
+	if overall == "degraded" {
+		return sseRetryDegraded
+	}
+	return sseRetryHealthy
+}
+
+func databaseStatus(probed bool, err error) SubsystemStatus {
+	s := SubsystemStatus{Name: "db", State: subsystemOK}
+	switch {
+	case !probed:
+		s.State, s.Reason = subsystemOff, "not checked yet"
+	case err != nil:
+		msg := strings.ToLower(err.Error())
+		s.State = subsystemDown
+		switch {
+		case strings.Contains(msg, "readonly") || strings.Contains(msg, "read-only"):
+			s.Reason = "database is read-only; changes are not being saved"
+		case strings.Contains(msg, "disk is full") || strings.Contains(msg, "no space left"):
+			s.Reason = "disk is full; changes are not being saved"
+		default:
+			s.Reason = fmt.Sprintf("database writes failing: %v", err)
+		}
+	}
+	return s
+}
+
+func scannerStatus(cfg pmsettings.Settings, managed bool) SubsystemStatus {
+	s := SubsystemStatus{Name: "scanner", State: subsystemOK}
+	if !cfg.Discovery.IPScanningEnabled {
+		if managed {
+			s.State, s.Reason = subsystemDisabled, "IP scanning is disabled by server policy"
+		} else {
+			s.State, s.Reason = subsystemOff, "IP scanning is turned off in settings"
+		}
+	}
+	return s
+}
+
+func uploadStatus(w *UploadWorker) SubsystemStatus {
+	s := SubsystemStatus{Name: "upload", State: subsystemOK}
+	if w == nil {
+		s.State, s.Reason = subsystemOff, "not connected to a server"
+		return s
+	}
+	health := w.Status().Health
+	switch {
+	case health.BreakerOpen:
+		s.State, s.Reason = subsystemDown, "uploads paused: "+health.Message
+	case health.Status == agent.ServerHealthOK || health.Status == agent.ServerHealthUnknown:
+	default:
+		s.State, s.Reason = subsystemDegraded, health.Message
+	}
+	return s
+}
+
+func spoolerStatus(enabled, supported, running bool) SubsystemStatus {
+	s := SubsystemStatus{Name: "spooler", State: subsystemOK}
+	switch {
+	case !supported:
+		s.State, s.Reason = subsystemOff, "not supported on this platform"
+	case !enabled:
+		s.State, s.Reason = subsystemOff, "spooler tracking is turned off"
+	case !running:
+		s.State, s.Reason = subsystemDown, "spooler tracking is enabled but not running"
+	}
+	return s
+}
+
+func proxyStatus(w *UploadWorker, remoteProxyEnabled bool) SubsystemStatus {
+	s := SubsystemStatus{Name: "proxy", State: subsystemOK}
+	switch {
+	case w == nil:
+		s.State, s.Reason = subsystemOff, "not connected to a server"
+	case !remoteProxyEnabled:
+		s.State, s.Reason = subsystemOff, "remote proxy is not enabled for this tenant"
+	case !w.Status().WebSocketConnected:
+		s.State, s.Reason = subsystemDegraded, "WebSocket to the server is disconnected; remote access unavailable"
+	}
+	return s
+}
+
+// handleStatusSummary serves GET /api/v1/status/summary.
+func handleStatusSummary(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "GET only", http.StatusMethodNotAllowed)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(agentStatus.refresh())
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	pmsettings "printmaster/common/settings"
+)
+
+func TestSubsystemStatusClassification(t *testing.T) {
+	if s := databaseStatus(true, errors.New("attempt to write a readonly database")); s.State != subsystemDown || !strings.Contains(s.Reason, "read-only") {
+		t.Fatalf("read-only db: %+v", s)
+	}
+	if s := databaseStatus(true, errors.New("database or disk is full")); s.State != subsystemDown || !strings.Contains(s.Reason, "disk is full") {
+		t.Fatalf("full disk: %+v", s)
+	}
+	if s := databaseStatus(true, nil); s.State != subsystemOK {
+		t.Fatalf("healthy db: %+v", s)
+	}
+
+	cfg := pmsettings.DefaultSettings()
+	cfg.Discovery.IPScanningEnabled = false
+	if s := scannerStatus(cfg, true); s.State != subsystemDisabled {
+		t.Fatalf("scanner disabled by policy: %+v", s)
+	}
+	if s := scannerStatus(cfg, false); s.State != subsystemOff {
+		t.Fatalf("scanner turned off locally: %+v", s)
+	}
+	if s := spoolerStatus(true, true, false); s.State != subsystemDown {
+		t.Fatalf("enabled spooler not running: %+v", s)
+	}
+	if s := uploadStatus(nil); s.State != subsystemOff {
+		t.Fatalf("standalone upload: %+v", s)
+	}
+}
+
+func TestStatusTrackerBroadcastsTransitions(t *testing.T) {
+	prevHub := sseHub
+	sseHub = NewSSEHub()
+	defer func() {
+		sseHub.Stop()
+		sseHub = prevHub
+	}()
+	client := sseHub.NewClient()
+
+	tracker := &statusTracker{last: make(map[string]SubsystemStatus)}
+	t0 := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	healthy := []SubsystemStatus{{Name: "db", State: subsystemOK}}
+	if sum := tracker.record(healthy, t0); sum.Overall != "ok" || sum.RetryMs != sseRetryHealthy.Milliseconds() {
+		t.Fatalf("initial summary: %+v", sum)
+	}
+
+	t1 := t0.Add(time.Minute)
+	sum := tracker.record([]SubsystemStatus{{Name: "db", State: subsystemDown, Reason: "disk is full"}}, t1)
+	if sum.Overall != "degraded" || !sum.Subsystems[0].Since.Equal(t1) || sum.RetryMs != sseRetryDegraded.Milliseconds() {
+		t.Fatalf("degraded summary: %+v", sum)
+	}
+	select {
+	case ev := <-client.events:
+		if ev.Type != statusChangedEvent || ev.Data["subsystem"] != "db" || ev.Data["previous"] != subsystemOK || ev.Data["overall"] != "degraded" {
+			t.Fatalf("unexpected event: %+v", ev)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("expected status_changed event")
+	}
+
+	// Unchanged state keeps its since time and sends nothing
+	sum = tracker.record([]SubsystemStatus{{Name: "db", State: subsystemDown, Reason: "disk is full"}}, t1.Add(time.Minute))
+	if !sum.Subsystems[0].Since.Equal(t1) {
+		t.Fatalf("since must not move without a transition: %+v", sum.Subsystems[0])
+	}
+	select {
+	case ev := <-client.events:
+		t.Fatalf("unexpected event: %+v", ev)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestHandleStatusSummary(t *testing.T) {
+	rec := httptest.NewRecorder()
+	handleStatusSummary(rec, httptest.NewRequest(http.MethodGet, "/api/v1/status/summary", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status %d", rec.Code)
+	}
+	var summary StatusSummary
+	if err := json.Unmarshal(rec.Body.Bytes(), &summary); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	names := make([]string, 0, len(summary.Subsystems))
+	for _, s := range summary.Subsystems {
+		names = append(names, s.Name)
+	}
+	if got := strings.Join(names, ","); got != "db,scanner,upload,spooler,proxy" {
+		t.Fatalf("subsystems = %s", got)
+	}
+}
@@ -0,0 +1,127 @@
+package statuspage
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// counterPattern maps a counter label (as commonly printed on status pages) to
+// the Result field it populates.
+type counterPattern struct {
+	re    *regexp.Regexp
+	apply func(*Result, int)
+}
+
+// ocrNumber matches a counter value: a run of digits, optionally split into
+// thousands groups by commas, dots or single spaces. The letters OCR commonly
+// confuses with digits are allowed inside a group, but a space-separated group
+// must be three real digits and the value must end at a word boundary, so the
+// word after a number ("1234 of") is never read as part of it. Group sizes are
+// checked by parseOCRNumber.
+const ocrNumber = `([0-9][0-9OolI]*(?:[,.][0-9OolI]+)*(?: [0-9]{3})*)\b`
+
+// OCR output is noisy: labels may be split by dots/colons/whitespace and digits
+// may contain thousands separators, so patterns are intentionally loose.
+var counterPatterns = []counterPattern{
+	{
+		re:    regexp.MustCompile(`(?i)(?:total\s*(?:page|impression|print)s?(?:\s*count)?|page\s*count|total\s*counter)[\s.:=]*` + ocrNumber),
+		apply: func(r *Result, v int) { r.PageCount = v },
+	},
+	{
+		re:    regexp.MustCompile(`(?i)(?:black\s*(?:&|and)?\s*white|mono(?:chrome)?|b\s*/\s*w)\s*(?:page|print|impression)?s?[\s.:=]*` + ocrNumber),
+		apply: func(r *Result, v int) { r.MonoPages = v },
+	},
+	{
+		re:    regexp.MustCompile(`(?i)(?:full\s*)?colou?r\s*(?:page|print|impression)?s?[\s.:=]*` + ocrNumber),
+		apply: func(r *Result, v int) { r.ColorPages = v },
+	},
+	{
+		re:    regexp.MustCompile(`(?i)(?:total\s*)?scans?(?:\s*count)?[\s.:=]*` + ocrNumber),
+		apply: func(r *Result, v int) { r.ScanCount = v },
+	},
+}
+
+// ParseCounters extracts page counters from OCR text. Only the first match for
+// each counter is used; when no explicit total is printed it is derived from
+// the mono and color counters.
+func ParseCounters(text string) Result {
+	var res Result
+	for _, line := range strings.Split(text, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		for _, p := range counterPatterns {
+			m := p.re.FindStringSubmatch(line)
+			if len(m) < 2 {
+				continue
+			}
+			v, ok := parseOCRNumber(m[1])
+			if !ok {
+				continue
+			}
+			var probe Result
+			p.apply(&probe, v)
+			if alreadySet(res, probe) {
+				continue
+			}
+			p.apply(&res, v)
+			break
+		}
+	}
+	if res.PageCount == 0 && (res.MonoPages > 0 || res.ColorPages > 0) {
+		res.PageCount = res.MonoPages + res.ColorPages
+	}
+	return res
+}
+
+// alreadySet reports whether the counter populated in probe is already present in res.
+func alreadySet(res, probe Result) bool {
+	switch {
+	case probe.PageCount != 0:
+		return res.PageCount != 0
+	case probe.MonoPages != 0:
+		return res.MonoPages != 0
+	case probe.ColorPages != 0:
+		return res.ColorPages != 0
+	case probe.ScanCount != 0:
+		return res.ScanCount != 0
+	}
+	return true
+}
+
+// parseOCRNumber checks the thousands grouping of a matched value (every
+// group after the first has three characters, each group has at least one
+// real digit), then strips the separators and common OCR confusions
+// (O→0, l/I→1) before converting to an int.
+func parseOCRNumber(s string) (int, bool) {
+	groups := strings.FieldsFunc(s, func(r rune) bool { return r == ',' || r == '.' || r == ' ' })
+	for i, g := range groups {
+		if len(groups) > 1 && (len(g) > 3 || (i > 0 && len(g) != 3)) {
+			return 0, false
+		}
+		if !strings.ContainsAny(g, "0123456789") {
+			return 0, false
+		}
+	}
+	cleaned := strings.Map(func(r rune) rune {
+		switch r {
+		case ',', '.', ' ':
+			return -1
+		case 'O', 'o':
+			return '0'
+		case 'l', 'I':
+			return '1'
+		}
+		return r
+	}, s)
+	if cleaned == "" {
+		return 0, false
+	}
+	v, err := strconv.Atoi(cleaned)
+	if err != nil || v <= 0 {
+		return 0, false
+	}
+	return v, true
+}
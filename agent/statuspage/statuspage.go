@@ -0,0 +1,178 @@
+// Package statuspage provides a best-effort fallback for devices that only expose
+// their counters on a rendered status/configuration page image. The page image is
+// fetched from a vendor-specific endpoint and passed through a lightweight OCR
+// engine; recognized counters are always reported as low-confidence data.
+package statuspage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"printmaster/agent/devicetls"
+	"printmaster/common/logger"
+)
+
+// ConfidenceLow marks counters recovered via OCR. They should never overwrite
+// SNMP-sourced values and consumers should treat them as estimates.
+const ConfidenceLow = "low"
+
+// maxImageBytes caps the size of a downloaded status page image.
+const maxImageBytes = 8 << 20
+
+// ErrNoEndpoint is returned when no status page endpoint is known for a vendor.
+var ErrNoEndpoint = errors.New("no status page endpoint for vendor")
+
+// ErrNoCounters is returned when OCR succeeded but no counters could be parsed.
+var ErrNoCounters = errors.New("no counters recognized on status page")
+
+// Endpoint describes where a vendor serves its status page image.
+type Endpoint struct {
+	Vendor string // Vendor name as reported by detection (case-insensitive match)
+	Scheme string // "http" or "https"
+	Path   string // Request path including query string
+}
+
+// URL builds the absolute endpoint URL for a device IP.
+func (e Endpoint) URL(ip string) string {
+	scheme := e.Scheme
+	if scheme == "" {
+		scheme = "http"
+	}
+	return scheme + "://" + ip + e.Path
+}
+
+// DefaultEndpoints lists known status page image endpoints per vendor, in the
+// order they should be attempted. Older embedded web servers frequently render
+// the configuration page as a bitmap instead of HTML.
+var DefaultEndpoints = []Endpoint{
+	{Vendor: "HP", Scheme: "http", Path: "/hp/device/this.LCDispatcher?nav=hp.ConfigPage&format=bmp"},
+	{Vendor: "HP", Scheme: "http", Path: "/images/configpage.png"},
+	{Vendor: "Kyocera", Scheme: "http", Path: "/dvcinfo/dvccounter/DvcInfo_Counter.png"},
+	{Vendor: "Ricoh", Scheme: "http", Path: "/web/guest/en/websys/status/getCounterImage.cgi"},
+	{Vendor: "Brother", Scheme: "http", Path: "/printer/maininfo.png"},
+	{Vendor: "Samsung", Scheme: "http", Path: "/Information/counters.gif"},
+	{Vendor: "Xerox", Scheme: "http", Path: "/status/billingCounters.png"},
+	{Vendor: "Lexmark", Scheme: "http", Path: "/cgi-bin/dynamic/printer/config/reports/deviceinfo.png"},
+}
+
+// Recognizer converts an image into plain text.
+type Recognizer interface {
+	Recognize(ctx context.Context, image []byte) (string, error)
+}
+
+// Result holds counters recovered from a status page.
+type Result struct {
+	SourceURL  string    `json:"source_url"`
+	PageCount  int       `json:"page_count,omitempty"`
+	MonoPages  int       `json:"mono_pages,omitempty"`
+	ColorPages int       `json:"color_pages,omitempty"`
+	ScanCount  int       `json:"scan_count,omitempty"`
+	Confidence string    `json:"confidence"`
+	CapturedAt time.Time `json:"captured_at"`
+}
+
+// Extractor fetches status page images and runs OCR on them.
+type Extractor struct {
+	Client     *http.Client
+	Recognizer Recognizer
+	Endpoints  []Endpoint
+}
+
+// NewExtractor returns an Extractor using DefaultEndpoints and an HTTP client
+// that tolerates the self-signed certificates common on printer web UIs and
+// honours per-device TLS overrides.
+func NewExtractor(recognizer Recognizer, timeout time.Duration) *Extractor {
+	if timeout <= 0 {
+		timeout = 10 * time.Second
+	}
+	return &Extractor{
+		Client: &http.Client{
+			Timeout:   timeout,
+			Transport: devicetls.Transport(&http.Transport{}),
+		},
+		Recognizer: recognizer,
+		Endpoints:  DefaultEndpoints,
+	}
+}
+
+// EndpointsFor returns the configured endpoints that match a vendor name.
+func (e *Extractor) EndpointsFor(vendor string) []Endpoint {
+	var out []Endpoint
+	for _, ep := range e.Endpoints {
+		if strings.EqualFold(ep.Vendor, strings.TrimSpace(vendor)) {
+			out = append(out, ep)
+		}
+	}
+	return out
+}
+
+// Extract tries each known endpoint for the vendor until one yields counters.
+func (e *Extractor) Extract(ctx context.Context, ip, vendor string) (*Result, error) {
+	if e.Recognizer == nil {
+		return nil, fmt.Errorf("no OCR recognizer configured")
+	}
+	endpoints := e.EndpointsFor(vendor)
+	if len(endpoints) == 0 {
+		return nil, ErrNoEndpoint
+	}
+
+	var lastErr error
+	for _, ep := range endpoints {
+		url := ep.URL(ip)
+		img, err := e.fetch(ctx, url)
+		if err != nil {
+			lastErr = err
+			if logger.Global != nil {
+				logger.Global.Debug("Status page fetch failed", "ip", ip, "url", url, "error", err)
+			}
+			continue
+		}
+		text, err := e.Recognizer.Recognize(ctx, img)
+		if err != nil {
+			lastErr = fmt.Errorf("ocr failed: %w", err)
+			continue
+		}
+		counters := ParseCounters(text)
+		if counters.empty() {
+			lastErr = ErrNoCounters
+			continue
+		}
+		counters.SourceURL = url
+		counters.Confidence = ConfidenceLow
+		counters.CapturedAt = time.Now().UTC()
+		return &counters, nil
+	}
+	return nil, lastErr
+}
+
+func (e *Extractor) fetch(ctx context.Context, url string) ([]byte, error) {
+	client := e.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+	if ct := resp.Header.Get("Content-Type"); ct != "" && !strings.HasPrefix(ct, "image/") && ct != "application/octet-stream" {
+		return nil, fmt.Errorf("unexpected content type %q", ct)
+	}
+	return io.ReadAll(io.LimitReader(resp.Body, maxImageBytes))
+}
+
+func (r Result) empty() bool {
+	return r.PageCount == 0 && r.MonoPages == 0 && r.ColorPages == 0 && r.ScanCount == 0
+}
@@ -0,0 +1,158 @@
+package statuspage
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+type fakeRecognizer struct {
+	text string
+	err  error
+}
+
+func (f fakeRecognizer) Recognize(ctx context.Context, image []byte) (string, error) {
+	return f.text, f.err
+}
+
+func TestParseCounters(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name string
+		text string
+		want Result
+	}{
+		{
+			name: "total with separators",
+			text: "Printer Configuration\nTotal Pages: 12,345\nScan Count .... 77\n",
+			want: Result{PageCount: 12345, ScanCount: 77},
+		},
+		{
+			name: "mono and color derive total",
+			text: "Black & White Pages 1 000\nColor Pages: 250",
+			want: Result{PageCount: 1250, MonoPages: 1000, ColorPages: 250},
+		},
+		{
+			name: "ocr confusions",
+			text: "Page Count: 1O2l",
+			want: Result{PageCount: 1021},
+		},
+		{
+			name: "first match wins",
+			text: "Total Pages: 500\nTotal Pages: 999",
+			want: Result{PageCount: 500},
+		},
+		{
+			name: "word after the number",
+			text: "Total Pages: 1234 of 5000\nColor Pages: 250 I",
+			want: Result{PageCount: 1234, ColorPages: 250},
+		},
+		{
+			name: "word run into the number",
+			text: "Page Count: 1234of",
+			want: Result{},
+		},
+		{
+			name: "letters in a separated group",
+			text: "Total Pages: 12,lOl",
+			want: Result{},
+		},
+		{
+			name: "bad thousands grouping",
+			text: "Total Pages: 1,2345\nMono Pages: 1234,567",
+			want: Result{},
+		},
+		{
+			name: "space group must be digits",
+			text: "Scan Count: 77 lol",
+			want: Result{ScanCount: 77},
+		},
+		{
+			name: "mixed separators",
+			text: "Total Counter = 1.234,567",
+			want: Result{PageCount: 1234567},
+		},
+		{
+			name: "no counters",
+			text: "Toner Low\nReady",
+			want: Result{},
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			got := ParseCounters(tt.text)
+			if got.PageCount != tt.want.PageCount || got.MonoPages != tt.want.MonoPages ||
+				got.ColorPages != tt.want.ColorPages || got.ScanCount != tt.want.ScanCount {
+				t.Fatalf("ParseCounters() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestExtractUsesVendorEndpoints(t *testing.T) {
+	t.Parallel()
+
+	var hits []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits = append(hits, r.URL.Path)
+		if r.URL.Path == "/missing.png" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "image/png")
+		_, _ = w.Write([]byte{0x89, 'P', 'N', 'G'})
+	}))
+	defer srv.Close()
+
+	host := strings.TrimPrefix(srv.URL, "http://")
+	ex := NewExtractor(fakeRecognizer{text: "Total Impressions: 4321"}, time.Second)
+	ex.Endpoints = []Endpoint{
+		{Vendor: "HP", Scheme: "http", Path: "/missing.png"},
+		{Vendor: "HP", Scheme: "http", Path: "/config.png"},
+		{Vendor: "Canon", Scheme: "http", Path: "/canon.png"},
+	}
+
+	res, err := ex.Extract(context.Background(), host, "hp")
+	if err != nil {
+		t.Fatalf("Extract() error = %v", err)
+	}
+	if res.PageCount != 4321 {
+		t.Fatalf("PageCount = %d, want 4321", res.PageCount)
+	}
+	if res.Confidence != ConfidenceLow {
+		t.Fatalf("Confidence = %q, want %q", res.Confidence, ConfidenceLow)
+	}
+	if !strings.HasSuffix(res.SourceURL, "/config.png") {
+		t.Fatalf("SourceURL = %q", res.SourceURL)
+	}
+	if len(hits) != 2 {
+		t.Fatalf("expected 2 requests, got %v", hits)
+	}
+}
+
+func TestExtractErrors(t *testing.T) {
+	t.Parallel()
+
+	ex := NewExtractor(fakeRecognizer{text: "nothing useful"}, time.Second)
+	ex.Endpoints = nil
+	if _, err := ex.Extract(context.Background(), "127.0.0.1", "HP"); !errors.Is(err, ErrNoEndpoint) {
+		t.Fatalf("expected ErrNoEndpoint, got %v", err)
+	}
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "image/gif")
+		_, _ = w.Write([]byte("GIF89a"))
+	}))
+	defer srv.Close()
+	ex.Endpoints = []Endpoint{{Vendor: "HP", Path: "/status.gif"}}
+	if _, err := ex.Extract(context.Background(), strings.TrimPrefix(srv.URL, "http://"), "HP"); !errors.Is(err, ErrNoCounters) {
+		t.Fatalf("expected ErrNoCounters, got %v", err)
+	}
+}
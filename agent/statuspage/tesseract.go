@@ -0,0 +1,43 @@
+package statuspage
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os/exec"
+)
+
+// TesseractRecognizer runs the tesseract CLI, reading the image from stdin and
+// writing recognized text to stdout. No OCR library is linked into the agent;
+// the fallback is only available on hosts where tesseract is installed.
+type TesseractRecognizer struct {
+	Path string // Absolute path to the tesseract binary
+}
+
+// NewTesseractRecognizer locates tesseract on PATH. It returns nil when the
+// binary is not installed so callers can skip OCR cleanly.
+func NewTesseractRecognizer() *TesseractRecognizer {
+	path, err := exec.LookPath("tesseract")
+	if err != nil {
+		return nil
+	}
+	return &TesseractRecognizer{Path: path}
+}
+
+// Recognize implements Recognizer.
+func (t *TesseractRecognizer) Recognize(ctx context.Context, image []byte) (string, error) {
+	if t == nil || t.Path == "" {
+		return "", fmt.Errorf("tesseract not available")
+	}
+	// --psm 6 treats the page as a single uniform block of text, which suits
+	// tabular counter pages better than the default layout analysis.
+	cmd := exec.CommandContext(ctx, t.Path, "stdin", "stdout", "--psm", "6")
+	cmd.Stdin = bytes.NewReader(image)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("tesseract: %w: %s", err, bytes.TrimSpace(stderr.Bytes()))
+	}
+	return stdout.String(), nil
+}
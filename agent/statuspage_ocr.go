@@ -0,0 +1,141 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"time"
+
+	"printmaster/agent/agent"
+	"printmaster/agent/featureflags"
+	"printmaster/agent/statuspage"
+	"printmaster/agent/storage"
+)
+
+// statusPageOCRTimeout bounds the fetch+OCR time per device so a slow embedded
+// web server cannot stall discovery persistence.
+const statusPageOCRTimeout = 20 * time.Second
+
+var (
+	statusPageExtractorOnce sync.Once
+	statusPageExtractor     *statuspage.Extractor
+)
+
+// getStatusPageExtractor lazily builds the extractor. It returns nil when no
+// OCR engine is installed on this host.
+func getStatusPageExtractor() *statuspage.Extractor {
+	statusPageExtractorOnce.Do(func() {
+		recognizer := statuspage.NewTesseractRecognizer()
+		if recognizer == nil {
+			if appLogger != nil {
+				appLogger.Warn("Status page OCR enabled but tesseract was not found on PATH")
+			}
+			return
+		}
+		statusPageExtractor = statuspage.NewExtractor(recognizer, 10*time.Second)
+	})
+	return statusPageExtractor
+}
+
+// applyStatusPageOCR fills missing page counters from the vendor status page
+// image when SNMP returned none. Counters already reported via SNMP are never
+// overwritten. Returns the OCR result so callers can flag it on the snapshot.
+func applyStatusPageOCR(ctx context.Context, pi *agent.PrinterInfo) *statuspage.Result {
+	if pi == nil || pi.PageCount > 0 || pi.TotalMonoImpressions > 0 || pi.MonoImpressions > 0 || pi.ColorImpressions > 0 {
+		return nil
+	}
+	res := extractStatusPageCounters(ctx, pi.IP, pi.Manufacturer, pi.Serial)
+	if res == nil {
+		return nil
+	}
+	pi.PageCount = res.PageCount
+	pi.MonoImpressions = res.MonoPages
+	pi.ColorImpressions = res.ColorPages
+	return res
+}
+
+// applyStatusPageOCRMetrics is the periodic collection counterpart of
+// applyStatusPageOCR: it runs the fallback when a collected snapshot has no page
+// counters and marks the snapshot with the OCR source and confidence.
+func applyStatusPageOCRMetrics(ctx context.Context, device *storage.Device, snapshot *storage.MetricsSnapshot) *statuspage.Result {
+	if device == nil || snapshot == nil || snapshot.PageCount > 0 || snapshot.MonoPages > 0 || snapshot.ColorPages > 0 {
+		return nil
+	}
+	res := extractStatusPageCounters(ctx, device.IP, device.Manufacturer, device.Serial)
+	if res == nil {
+		return nil
+	}
+	markStatusPageOCRMetrics(snapshot, res)
+	return res
+}
+
+// markStatusPageOCRMetrics copies OCR counters into a metrics snapshot without
+// overwriting SNMP values, and records their source and confidence.
+func markStatusPageOCRMetrics(snapshot *storage.MetricsSnapshot, res *statuspage.Result) {
+	if snapshot == nil || res == nil {
+		return
+	}
+	fill := func(dst *int, v int) {
+		if *dst == 0 {
+			*dst = v
+		}
+	}
+	fill(&snapshot.PageCount, res.PageCount)
+	fill(&snapshot.MonoPages, res.MonoPages)
+	fill(&snapshot.ColorPages, res.ColorPages)
+	fill(&snapshot.ScanCount, res.ScanCount)
+	snapshot.CounterSource = storage.CounterSourceStatusPageOCR
+	snapshot.CounterConfidence = res.Confidence
+}
+
+// extractStatusPageCounters fetches and OCRs the status page of one device.
+// Returns nil when the feature is off, no OCR engine exists, or nothing was read.
+func extractStatusPageCounters(ctx context.Context, ip, manufacturer, serial string) *statuspage.Result {
+	if !featureflags.StatusPageOCREnabled() || ip == "" || manufacturer == "" {
+		return nil
+	}
+	extractor := getStatusPageExtractor()
+	if extractor == nil {
+		return nil
+	}
+
+	ocrCtx, cancel := context.WithTimeout(ctx, statusPageOCRTimeout)
+	defer cancel()
+	res, err := extractor.Extract(ocrCtx, ip, manufacturer)
+	if err != nil {
+		if appLogger != nil {
+			appLogger.Debug("Status page OCR produced no counters", "ip", ip, "manufacturer", manufacturer, "error", err)
+		}
+		return nil
+	}
+	if appLogger != nil {
+		appLogger.Info("Page counters recovered from status page OCR",
+			"ip", ip, "serial", serial, "page_count", res.PageCount, "source", res.SourceURL)
+	}
+	return res
+}
+
+// attachStatusPageOCR records the OCR result on the device and scan snapshot so
+// consumers can tell the counters are low-confidence. Existing raw data is kept.
+func attachStatusPageOCR(device *storage.Device, snapshot *storage.ScanSnapshot, res *statuspage.Result) {
+	if device != nil {
+		if device.RawData == nil {
+			device.RawData = map[string]interface{}{}
+		}
+		device.RawData["status_page_ocr"] = res
+		device.RawData["page_count_confidence"] = res.Confidence
+	}
+	if snapshot != nil {
+		raw := map[string]interface{}{}
+		if len(snapshot.RawData) > 0 {
+			if err := json.Unmarshal(snapshot.RawData, &raw); err != nil || raw == nil {
+				raw = map[string]interface{}{}
+			}
+		}
+		raw["status_page_ocr"] = res
+		raw["page_count_confidence"] = res.Confidence
+		if merged, err := json.Marshal(raw); err == nil {
+			snapshot.RawData = merged
+		}
+	}
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+
+	"printmaster/agent/statuspage"
+	"printmaster/agent/storage"
+)
+
+func TestMarkStatusPageOCRMetrics(t *testing.T) {
+	t.Parallel()
+	res := &statuspage.Result{PageCount: 1200, MonoPages: 1000, ColorPages: 200, ScanCount: 340, Confidence: "medium"}
+
+	snapshot := &storage.MetricsSnapshot{}
+	snapshot.ScanCount = 50
+	markStatusPageOCRMetrics(snapshot, res)
+	if snapshot.PageCount != 1200 || snapshot.MonoPages != 1000 || snapshot.ColorPages != 200 {
+		t.Errorf("counters not copied: %+v", snapshot)
+	}
+	if snapshot.ScanCount != 50 {
+		t.Errorf("SNMP scan count overwritten: %d", snapshot.ScanCount)
+	}
+	if snapshot.CounterSource != storage.CounterSourceStatusPageOCR || snapshot.CounterConfidence != "medium" {
+		t.Errorf("source = %q confidence = %q", snapshot.CounterSource, snapshot.CounterConfidence)
+	}
+
+	fromOCR := &storage.MetricsSnapshot{}
+	markStatusPageOCRMetrics(fromOCR, res)
+	if fromOCR.ScanCount != 340 {
+		t.Errorf("scan count = %d, want 340", fromOCR.ScanCount)
+	}
+}
+
+func TestAttachStatusPageOCRMergesRawData(t *testing.T) {
+	t.Parallel()
+	res := &statuspage.Result{PageCount: 1200, Confidence: "low"}
+	device := &storage.Device{}
+	device.RawData = map[string]interface{}{"asset_id": "A-17"}
+	snapshot := &storage.ScanSnapshot{RawData: json.RawMessage(`{"hostname_source":"dns"}`)}
+
+	attachStatusPageOCR(device, snapshot, res)
+
+	if device.RawData["asset_id"] != "A-17" || device.RawData["page_count_confidence"] != "low" {
+		t.Errorf("device raw data = %+v", device.RawData)
+	}
+	var raw map[string]interface{}
+	if err := json.Unmarshal(snapshot.RawData, &raw); err != nil {
+		t.Fatalf("decode snapshot raw data: %v", err)
+	}
+	if raw["hostname_source"] != "dns" || raw["page_count_confidence"] != "low" || raw["status_page_ocr"] == nil {
+		t.Errorf("snapshot raw data = %+v", raw)
+	}
+}
@@ -4,6 +4,8 @@ import (
 	"database/sql"
 	"encoding/json"
 	"fmt"
+	"os"
+	"path/filepath"
 	"strings"
 	"sync"
 )
@@ -22,32 +24,66 @@ type AgentConfigStore interface {
 	DeleteConfigValue(key string) error
 	// GetConfigValue retrieves any JSON-serializable config value
 	GetConfigValue(key string, dest interface{}) error
+	// ListSNMPProfiles returns SNMP credential profiles ordered by priority
+	ListSNMPProfiles() ([]SNMPProfile, error)
+	// SaveSNMPProfile inserts (ID 0) or replaces an SNMP credential profile
+	SaveSNMPProfile(p *SNMPProfile) error
+	// DeleteSNMPProfile removes an SNMP credential profile by ID
+	DeleteSNMPProfile(id int64) error
 	// Close closes the database connection
 	Close() error
 }
 
 // SQLiteAgentConfig implements AgentConfigStore using SQLite
 type SQLiteAgentConfig struct {
-	db *sql.DB
-	mu sync.RWMutex
+	db  *sql.DB
+	enc *encryptedDB // Non-nil when encrypted at rest
+	mu  sync.RWMutex
 }
 
 // NewAgentConfigStore creates a new AgentConfigStore with SQLite backend
 func NewAgentConfigStore(dbPath string) (AgentConfigStore, error) {
-	db, err := sql.Open("sqlite", dbPath)
+	db, enc, err := openSQLite(dbPath)
 	if err != nil {
 		return nil, fmt.Errorf("failed to open agent config database: %w", err)
 	}
 
-	store := &SQLiteAgentConfig{db: db}
+	store := &SQLiteAgentConfig{db: db, enc: enc}
 	if err := store.initialize(); err != nil {
-		db.Close()
+		store.Close()
 		return nil, err
 	}
 
 	return store, nil
 }
 
+// OpenAgentConfigReadOnly opens an existing agent config database for
+// short-lived commands. Nothing is created, migrated or sealed: with a key the
+// sealed image is read (falling back to a plaintext file left as is), without
+// one only the plaintext file. Returns ErrNotFound when neither exists.
+func OpenAgentConfigReadOnly(dbPath string, key []byte) (AgentConfigStore, error) {
+	if key != nil {
+		if _, err := os.Stat(EncryptedPath(dbPath)); err == nil {
+			db, enc, err := openSealedReadOnly(dbPath, key)
+			if err != nil {
+				return nil, fmt.Errorf("failed to open agent config database: %w", err)
+			}
+			return &SQLiteAgentConfig{db: db, enc: enc}, nil
+		}
+	}
+	if _, err := os.Stat(dbPath); err != nil {
+		if os.IsNotExist(err) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	db, err := sql.Open("sqlite", "file:"+filepath.ToSlash(dbPath)+"?mode=ro")
+	if err != nil {
+		return nil, fmt.Errorf("failed to open agent config database: %w", err)
+	}
+	return &SQLiteAgentConfig{db: db}, nil
+}
+
 // initialize creates the necessary tables
 func (s *SQLiteAgentConfig) initialize() error {
 	schema := `
@@ -63,6 +99,9 @@ func (s *SQLiteAgentConfig) initialize() error {
 	if _, err := s.db.Exec(schema); err != nil {
 		return fmt.Errorf("failed to create agent_config schema: %w", err)
 	}
+	if _, err := s.db.Exec(snmpProfilesSchema); err != nil {
+		return fmt.Errorf("failed to create snmp_profiles schema: %w", err)
+	}
 
 	return nil
 }
@@ -128,6 +167,11 @@ func (s *SQLiteAgentConfig) GetRangesList() ([]string, error) {
 
 // Close closes the database connection
 func (s *SQLiteAgentConfig) Close() error {
+	if s.enc != nil {
+		if err := s.enc.Close(); err != nil && storageLogger != nil {
+			storageLogger.Error("Failed to write encrypted database", "path", s.enc.path, "error", err)
+		}
+	}
 	return s.db.Close()
 }
 
@@ -0,0 +1,138 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// Alert is an alert raised by the agent's local alert engine. Key identifies
+// the condition (device, type and supply); at most one alert per key is open
+// at a time.
+type Alert struct {
+	ID         int64      `json:"id"`
+	Key        string     `json:"key"`
+	Type       string     `json:"type"`
+	Severity   string     `json:"severity"`
+	Serial     string     `json:"serial"`
+	IP         string     `json:"ip,omitempty"`
+	Supply     string     `json:"supply,omitempty"`
+	Title      string     `json:"title"`
+	Message    string     `json:"message,omitempty"`
+	Value      int        `json:"value"`
+	Threshold  int        `json:"threshold"`
+	RaisedAt   time.Time  `json:"raised_at"`
+	ResolvedAt *time.Time `json:"resolved_at,omitempty"`
+}
+
+// AlertFilter selects alerts to list.
+type AlertFilter struct {
+	Serial   string // Only alerts for this device
+	OpenOnly bool   // Only alerts that have not resolved
+	Limit    int    // Max results (0 = no limit)
+}
+
+// AlertStore defines operations for locally raised alerts.
+type AlertStore interface {
+	// AddAlert stores a raised alert, setting alert.ID
+	AddAlert(ctx context.Context, alert *Alert) error
+
+	// ResolveAlert marks the open alert with key resolved (no-op if none is open)
+	ResolveAlert(ctx context.Context, key string, at time.Time) error
+
+	// ListAlerts returns alerts matching the filter, newest first
+	ListAlerts(ctx context.Context, filter AlertFilter) ([]*Alert, error)
+
+	// DeleteResolvedAlertsBefore removes alerts resolved before the cutoff (unix seconds)
+	DeleteResolvedAlertsBefore(ctx context.Context, olderThan int64) (int, error)
+}
+
+// AddAlert stores a raised alert, setting alert.ID.
+func (s *SQLiteStore) AddAlert(ctx context.Context, alert *Alert) error {
+	if alert == nil || alert.Serial == "" {
+		return ErrInvalidSerial
+	}
+	if alert.RaisedAt.IsZero() {
+		alert.RaisedAt = time.Now()
+	}
+	result, err := s.db.ExecContext(ctx, `
+		INSERT INTO alerts (alert_key, type, severity, serial, ip, supply, title, message, value, threshold, raised_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`, alert.Key, alert.Type, alert.Severity, alert.Serial, alert.IP, alert.Supply, alert.Title, alert.Message,
+		alert.Value, alert.Threshold, alert.RaisedAt.UTC())
+	if err != nil {
+		return fmt.Errorf("failed to add alert: %w", err)
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return fmt.Errorf("failed to get alert id: %w", err)
+	}
+	alert.ID = id
+	return nil
+}
+
+// ResolveAlert marks the open alert with key resolved.
+func (s *SQLiteStore) ResolveAlert(ctx context.Context, key string, at time.Time) error {
+	if _, err := s.db.ExecContext(ctx,
+		"UPDATE alerts SET resolved_at = ? WHERE alert_key = ? AND resolved_at IS NULL", at.UTC(), key); err != nil {
+		return fmt.Errorf("failed to resolve alert: %w", err)
+	}
+	return nil
+}
+
+// ListAlerts returns alerts matching the filter, newest first.
+func (s *SQLiteStore) ListAlerts(ctx context.Context, filter AlertFilter) ([]*Alert, error) {
+	query := `SELECT id, alert_key, type, severity, serial, ip, supply, title, message, value, threshold, raised_at, resolved_at
+		FROM alerts WHERE 1=1`
+	var args []interface{}
+	if filter.Serial != "" {
+		query += " AND serial = ?"
+		args = append(args, filter.Serial)
+	}
+	if filter.OpenOnly {
+		query += " AND resolved_at IS NULL"
+	}
+	query += " ORDER BY raised_at DESC, id DESC"
+	if filter.Limit > 0 {
+		query += " LIMIT ?"
+		args = append(args, filter.Limit)
+	}
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query alerts: %w", err)
+	}
+	defer rows.Close()
+
+	alerts := []*Alert{}
+	for rows.Next() {
+		var a Alert
+		var ip, supply, message sql.NullString
+		var resolved sql.NullTime
+		if err := rows.Scan(&a.ID, &a.Key, &a.Type, &a.Severity, &a.Serial, &ip, &supply, &a.Title, &message,
+			&a.Value, &a.Threshold, &a.RaisedAt, &resolved); err != nil {
+			return nil, fmt.Errorf("failed to scan alert: %w", err)
+		}
+		a.IP = ip.String
+		a.Supply = supply.String
+		a.Message = message.String
+		if resolved.Valid {
+			at := resolved.Time
+			a.ResolvedAt = &at
+		}
+		alerts = append(alerts, &a)
+	}
+	return alerts, rows.Err()
+}
+
+// DeleteResolvedAlertsBefore removes alerts resolved before the cutoff (unix seconds).
+func (s *SQLiteStore) DeleteResolvedAlertsBefore(ctx context.Context, olderThan int64) (int, error) {
+	result, err := s.db.ExecContext(ctx,
+		"DELETE FROM alerts WHERE resolved_at IS NOT NULL AND resolved_at < ?", time.Unix(olderThan, 0).UTC())
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete old alerts: %w", err)
+	}
+	rows, _ := result.RowsAffected()
+	return int(rows), nil
+}
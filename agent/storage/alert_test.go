@@ -0,0 +1,48 @@
+package storage
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestSQLiteStore_Alerts(t *testing.T) {
+	store, err := NewSQLiteStore(":memory:")
+	if err != nil {
+		t.Fatalf("failed to create store: %v", err)
+	}
+	defer store.Close()
+
+	ctx := context.Background()
+	old := time.Now().Add(-60 * 24 * time.Hour)
+	stale := &Alert{Key: "A1|paper_jam", Type: "paper_jam", Severity: "warning", Serial: "A1", Title: "Paper jam", RaisedAt: old}
+	toner := &Alert{Key: "A1|toner_low|black", Type: "toner_low", Severity: "warning", Serial: "A1", Supply: "black",
+		Title: "Toner low", Value: 8, Threshold: 10, RaisedAt: time.Now()}
+	offline := &Alert{Key: "B2|device_offline", Type: "device_offline", Severity: "critical", Serial: "B2", Title: "Offline", RaisedAt: time.Now()}
+	for _, a := range []*Alert{stale, toner, offline} {
+		if err := store.AddAlert(ctx, a); err != nil {
+			t.Fatalf("AddAlert: %v", err)
+		}
+	}
+	if err := store.ResolveAlert(ctx, stale.Key, old.Add(time.Hour)); err != nil {
+		t.Fatalf("ResolveAlert: %v", err)
+	}
+
+	open, err := store.ListAlerts(ctx, AlertFilter{OpenOnly: true})
+	if err != nil || len(open) != 2 {
+		t.Fatalf("open alerts: %d (%v)", len(open), err)
+	}
+	forA1, err := store.ListAlerts(ctx, AlertFilter{Serial: "A1"})
+	if err != nil || len(forA1) != 2 || forA1[0].Supply != "black" || forA1[1].ResolvedAt == nil {
+		t.Fatalf("alerts for A1: %+v (%v)", forA1, err)
+	}
+
+	deleted, err := store.DeleteResolvedAlertsBefore(ctx, time.Now().Add(-30*24*time.Hour).Unix())
+	if err != nil || deleted != 1 {
+		t.Fatalf("DeleteResolvedAlertsBefore: %d (%v)", deleted, err)
+	}
+	all, _ := store.ListAlerts(ctx, AlertFilter{})
+	if len(all) != 2 {
+		t.Fatalf("expected open alerts to be kept, got %d", len(all))
+	}
+}
@@ -0,0 +1,149 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// DeviceConfigBaseline is the last known value of each configuration setting
+// read from a device (sysLocation, sysContact, network settings, tray media).
+// Settings a scan could not read keep their previous value.
+type DeviceConfigBaseline struct {
+	Serial     string            `json:"serial"`
+	Values     map[string]string `json:"values"`
+	CapturedAt time.Time         `json:"captured_at"`
+}
+
+// ConfigDriftEvent records a configuration setting that changed on a device
+// between two scans.
+type ConfigDriftEvent struct {
+	ID         int64     `json:"id"`
+	Serial     string    `json:"serial"`
+	IP         string    `json:"ip,omitempty"`
+	Field      string    `json:"field"`
+	Before     string    `json:"before"`
+	After      string    `json:"after"`
+	DetectedAt time.Time `json:"detected_at"`
+}
+
+// ConfigDriftStore defines operations for device configuration baselines and
+// the drift recorded against them.
+type ConfigDriftStore interface {
+	// GetDeviceConfigBaseline returns the baseline for a device (nil if none)
+	GetDeviceConfigBaseline(ctx context.Context, serial string) (*DeviceConfigBaseline, error)
+
+	// SaveDeviceConfigBaseline replaces a device's baseline and stores the drift
+	// events found against the previous one, setting their IDs
+	SaveDeviceConfigBaseline(ctx context.Context, baseline *DeviceConfigBaseline, events []*ConfigDriftEvent) error
+
+	// ListConfigDriftEvents returns drift events for a device (all devices if serial is empty), newest first
+	ListConfigDriftEvents(ctx context.Context, serial string, limit int) ([]*ConfigDriftEvent, error)
+
+	// DeleteConfigDriftEventsBefore removes events detected before the cutoff (unix seconds)
+	DeleteConfigDriftEventsBefore(ctx context.Context, olderThan int64) (int, error)
+}
+
+// GetDeviceConfigBaseline returns the baseline for a device (nil if none).
+func (s *SQLiteStore) GetDeviceConfigBaseline(ctx context.Context, serial string) (*DeviceConfigBaseline, error) {
+	var values string
+	b := &DeviceConfigBaseline{Serial: serial}
+	err := s.db.QueryRowContext(ctx,
+		`SELECT config, captured_at FROM device_config_baselines WHERE serial = ?`, serial,
+	).Scan(&values, &b.CapturedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get device config baseline: %w", err)
+	}
+	if err := json.Unmarshal([]byte(values), &b.Values); err != nil {
+		return nil, fmt.Errorf("failed to decode device config baseline: %w", err)
+	}
+	return b, nil
+}
+
+// SaveDeviceConfigBaseline replaces a device's baseline and stores the drift
+// events found against the previous one, setting their IDs.
+func (s *SQLiteStore) SaveDeviceConfigBaseline(ctx context.Context, baseline *DeviceConfigBaseline, events []*ConfigDriftEvent) error {
+	if baseline == nil || baseline.Serial == "" {
+		return ErrInvalidSerial
+	}
+	values, err := json.Marshal(baseline.Values)
+	if err != nil {
+		return fmt.Errorf("failed to encode device config baseline: %w", err)
+	}
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, `
+		INSERT INTO device_config_baselines (serial, config, captured_at)
+		VALUES (?, ?, ?)
+		ON CONFLICT(serial) DO UPDATE SET config = excluded.config, captured_at = excluded.captured_at
+	`, baseline.Serial, string(values), baseline.CapturedAt.UTC()); err != nil {
+		return fmt.Errorf("failed to save device config baseline: %w", err)
+	}
+	for _, e := range events {
+		result, err := tx.ExecContext(ctx, `
+			INSERT INTO config_drift_events (serial, ip, field, before_value, after_value, detected_at)
+			VALUES (?, ?, ?, ?, ?, ?)
+		`, e.Serial, e.IP, e.Field, e.Before, e.After, e.DetectedAt.UTC())
+		if err != nil {
+			return fmt.Errorf("failed to add config drift event: %w", err)
+		}
+		if e.ID, err = result.LastInsertId(); err != nil {
+			return fmt.Errorf("failed to get config drift event id: %w", err)
+		}
+	}
+	return tx.Commit()
+}
+
+// ListConfigDriftEvents returns drift events for a device (all devices if
+// serial is empty), newest first.
+func (s *SQLiteStore) ListConfigDriftEvents(ctx context.Context, serial string, limit int) ([]*ConfigDriftEvent, error) {
+	query := `SELECT id, serial, ip, field, before_value, after_value, detected_at FROM config_drift_events`
+	var args []interface{}
+	if serial != "" {
+		query += " WHERE serial = ?"
+		args = append(args, serial)
+	}
+	query += " ORDER BY id DESC"
+	if limit > 0 {
+		query += " LIMIT ?"
+		args = append(args, limit)
+	}
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query config drift events: %w", err)
+	}
+	defer rows.Close()
+
+	var events []*ConfigDriftEvent
+	for rows.Next() {
+		var e ConfigDriftEvent
+		var ip, before, after sql.NullString
+		if err := rows.Scan(&e.ID, &e.Serial, &ip, &e.Field, &before, &after, &e.DetectedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan config drift event: %w", err)
+		}
+		e.IP, e.Before, e.After = ip.String, before.String, after.String
+		events = append(events, &e)
+	}
+	return events, rows.Err()
+}
+
+// DeleteConfigDriftEventsBefore removes events detected before the cutoff
+// (unix seconds).
+func (s *SQLiteStore) DeleteConfigDriftEventsBefore(ctx context.Context, olderThan int64) (int, error) {
+	result, err := s.db.ExecContext(ctx, `DELETE FROM config_drift_events WHERE detected_at < ?`,
+		time.Unix(olderThan, 0).UTC())
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete old config drift events: %w", err)
+	}
+	n, _ := result.RowsAffected()
+	return int(n), nil
+}
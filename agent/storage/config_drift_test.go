@@ -0,0 +1,67 @@
+package storage
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestSQLiteStore_ConfigDrift(t *testing.T) {
+	store, err := NewSQLiteStore(":memory:")
+	if err != nil {
+		t.Fatalf("failed to create store: %v", err)
+	}
+	defer store.Close()
+
+	ctx := context.Background()
+	if b, err := store.GetDeviceConfigBaseline(ctx, "SN1"); err != nil || b != nil {
+		t.Fatalf("GetDeviceConfigBaseline before capture = %+v, %v; want nil", b, err)
+	}
+
+	old := time.Now().Add(-60 * 24 * time.Hour)
+	first := &DeviceConfigBaseline{Serial: "SN1", Values: map[string]string{"location": "Room 1"}, CapturedAt: old}
+	if err := store.SaveDeviceConfigBaseline(ctx, first, []*ConfigDriftEvent{
+		{Serial: "SN1", IP: "10.0.0.5", Field: "location", Before: "Lobby", After: "Room 1", DetectedAt: old},
+	}); err != nil {
+		t.Fatalf("SaveDeviceConfigBaseline: %v", err)
+	}
+	now := time.Now()
+	drift := &ConfigDriftEvent{Serial: "SN1", IP: "10.0.0.5", Field: "location", Before: "Room 1", After: "Room 2", DetectedAt: now}
+	second := &DeviceConfigBaseline{Serial: "SN1", Values: map[string]string{"location": "Room 2", "gateway": "10.0.0.1"}, CapturedAt: now}
+	if err := store.SaveDeviceConfigBaseline(ctx, second, []*ConfigDriftEvent{drift}); err != nil {
+		t.Fatalf("SaveDeviceConfigBaseline (update): %v", err)
+	}
+	if drift.ID == 0 {
+		t.Fatal("expected drift event ID to be set")
+	}
+	if err := store.SaveDeviceConfigBaseline(ctx, &DeviceConfigBaseline{Serial: "SN2", Values: map[string]string{}, CapturedAt: now}, nil); err != nil {
+		t.Fatalf("SaveDeviceConfigBaseline (SN2): %v", err)
+	}
+
+	b, err := store.GetDeviceConfigBaseline(ctx, "SN1")
+	if err != nil || b == nil {
+		t.Fatalf("GetDeviceConfigBaseline: %+v, %v", b, err)
+	}
+	if b.Values["location"] != "Room 2" || b.Values["gateway"] != "10.0.0.1" || len(b.Values) != 2 {
+		t.Fatalf("baseline values = %v", b.Values)
+	}
+
+	events, err := store.ListConfigDriftEvents(ctx, "SN1", 0)
+	if err != nil {
+		t.Fatalf("ListConfigDriftEvents: %v", err)
+	}
+	if len(events) != 2 || events[0].After != "Room 2" || events[0].IP != "10.0.0.5" {
+		t.Fatalf("events = %+v, want newest (Room 2) first", events)
+	}
+	if events, _ := store.ListConfigDriftEvents(ctx, "SN2", 0); len(events) != 0 {
+		t.Fatalf("SN2 events = %+v, want none", events)
+	}
+	if events, _ := store.ListConfigDriftEvents(ctx, "", 1); len(events) != 1 {
+		t.Fatalf("limited events = %d, want 1", len(events))
+	}
+
+	deleted, err := store.DeleteConfigDriftEventsBefore(ctx, now.Add(-24*time.Hour).Unix())
+	if err != nil || deleted != 1 {
+		t.Fatalf("DeleteConfigDriftEventsBefore = %d, %v; want 1", deleted, err)
+	}
+}
@@ -1,6 +1,8 @@
 package storage
 
 import (
+	"encoding/json"
+
 	"printmaster/agent/agent"
 	"printmaster/agent/supplies"
 )
@@ -83,10 +85,63 @@ func PrinterInfoToDevice(pi agent.PrinterInfo, isSaved bool) *Device {
 		"form_factor": pi.FormFactor,
 		"device_type": pi.DeviceType,
 	}
+	if pi.SNMPVersion != "" {
+		device.RawData["snmp_version"] = pi.SNMPVersion
+		device.RawData["snmp_default_community"] = pi.SNMPDefaultCommunity
+	}
+	if pi.WebFingerprint != nil {
+		device.RawData["web_fingerprint"] = pi.WebFingerprint
+	}
+	if pi.SysObjectID != "" {
+		device.RawData["sys_object_id"] = pi.SysObjectID
+	}
+	if len(pi.SupplyReadings) > 0 {
+		device.RawData["supply_readings"] = pi.SupplyReadings
+	}
 
 	return device
 }
 
+// SupplyReadingsFromRawData returns the supply readings stored in a device's
+// RawData. It handles both the in-memory map and the decoded JSON map form.
+func SupplyReadingsFromRawData(raw map[string]interface{}) map[string]supplies.Reading {
+	switch v := raw["supply_readings"].(type) {
+	case map[string]supplies.Reading:
+		return v
+	case map[string]interface{}:
+		data, err := json.Marshal(v)
+		if err != nil {
+			return nil
+		}
+		var readings map[string]supplies.Reading
+		if err := json.Unmarshal(data, &readings); err != nil {
+			return nil
+		}
+		return readings
+	}
+	return nil
+}
+
+// WebFingerprintFromRawData returns the web fingerprint stored in a device's
+// RawData. It handles both the in-memory struct and the decoded JSON map form.
+func WebFingerprintFromRawData(raw map[string]interface{}) *agent.WebFingerprint {
+	switch v := raw["web_fingerprint"].(type) {
+	case *agent.WebFingerprint:
+		return v
+	case map[string]interface{}:
+		data, err := json.Marshal(v)
+		if err != nil {
+			return nil
+		}
+		var fp agent.WebFingerprint
+		if err := json.Unmarshal(data, &fp); err != nil {
+			return nil
+		}
+		return &fp
+	}
+	return nil
+}
+
 // DeviceToPrinterInfo converts storage.Device to agent.PrinterInfo
 func DeviceToPrinterInfo(device *Device) agent.PrinterInfo {
 	pi := agent.PrinterInfo{
@@ -134,6 +189,9 @@ func DeviceToPrinterInfo(device *Device) agent.PrinterInfo {
 		if v, ok := device.RawData["duplex_supported"].(bool); ok {
 			pi.DuplexSupported = v
 		}
+		if v, ok := device.RawData["sys_object_id"].(string); ok {
+			pi.SysObjectID = v
+		}
 		// Extract learned OIDs for efficient metrics collection
 		if v, ok := device.RawData["learned_oids"].(map[string]interface{}); ok {
 			learnedOIDs := agent.LearnedOIDMap{}
@@ -174,6 +232,14 @@ func DeviceToPrinterInfo(device *Device) agent.PrinterInfo {
 			}
 			pi.LearnedOIDs = learnedOIDs
 		}
+		pi.WebFingerprint = WebFingerprintFromRawData(device.RawData)
+		pi.SupplyReadings = SupplyReadingsFromRawData(device.RawData)
+		if v, ok := device.RawData["snmp_version"].(string); ok {
+			pi.SNMPVersion = v
+		}
+		if v, ok := device.RawData["snmp_default_community"].(bool); ok {
+			pi.SNMPDefaultCommunity = v
+		}
 		// Add more field extractions as needed
 
 		// Populate per-color toner fields and TonerLevels map from RawData when present
@@ -0,0 +1,155 @@
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// DeviceMerger folds a duplicate device record into its canonical record.
+type DeviceMerger interface {
+	// MergeDevice moves history, metrics and field locks from the device
+	// keyed by fromSerial onto toSerial and deletes fromSerial. Returns
+	// ErrNotFound if either device doesn't exist.
+	MergeDevice(ctx context.Context, fromSerial, toSerial string) error
+}
+
+// MergeDevice folds the fromSerial record into toSerial in one transaction.
+// The canonical record keeps its own values; user-entered fields it lacks
+// and locks it doesn't have are taken from the duplicate. Scan history, raw
+// metrics and the page count audit are moved over; aggregate buckets the
+// canonical record already has win over the duplicate's. Meter reads are
+// immutable and keep the serial they were certified under.
+func (s *SQLiteStore) MergeDevice(ctx context.Context, fromSerial, toSerial string) error {
+	if fromSerial == "" || toSerial == "" {
+		return ErrInvalidSerial
+	}
+	if fromSerial == toSerial {
+		return fmt.Errorf("cannot merge device %q into itself", fromSerial)
+	}
+	from, err := s.Get(ctx, fromSerial)
+	if err != nil {
+		return err
+	}
+	to, err := s.Get(ctx, toSerial)
+	if err != nil {
+		return err
+	}
+	mergeDeviceFields(to, from)
+	lockedFieldsJSON, _ := json.Marshal(to.LockedFields)
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("begin tx: %w", err)
+	}
+	committed := false
+	defer func() {
+		if !committed {
+			_ = tx.Rollback()
+		}
+	}()
+
+	if _, err := tx.ExecContext(ctx, `
+		UPDATE devices SET
+			ip = ?, manufacturer = ?, model = ?, hostname = ?, mac_address = ?,
+			first_seen = ?, created_at = ?, is_saved = ?, visible = ?,
+			asset_number = ?, location = ?, description = ?, web_ui_url = ?,
+			locked_fields = ?, initial_page_count = ?
+		WHERE serial = ?`,
+		to.IP, to.Manufacturer, to.Model, to.Hostname, to.MACAddress,
+		to.FirstSeen, to.CreatedAt, to.IsSaved, to.Visible,
+		to.AssetNumber, to.Location, to.Description, to.WebUIURL,
+		string(lockedFieldsJSON), to.InitialPageCount,
+		toSerial,
+	); err != nil {
+		return fmt.Errorf("failed to update canonical device: %w", err)
+	}
+
+	for _, table := range []string{"scan_history", "metrics_raw", "page_count_audit"} {
+		if _, err := tx.ExecContext(ctx, "UPDATE "+table+" SET serial = ? WHERE serial = ?", toSerial, fromSerial); err != nil {
+			return fmt.Errorf("failed to move %s: %w", table, err)
+		}
+	}
+	// Aggregates are unique per (serial, bucket); conflicting rows stay on
+	// the duplicate and are removed with it below.
+	for _, table := range []string{"metrics_hourly", "metrics_daily", "metrics_monthly"} {
+		if _, err := tx.ExecContext(ctx, "UPDATE OR IGNORE "+table+" SET serial = ? WHERE serial = ?", toSerial, fromSerial); err != nil {
+			return fmt.Errorf("failed to move %s: %w", table, err)
+		}
+	}
+
+	if _, err := tx.ExecContext(ctx, "DELETE FROM devices WHERE serial = ?", fromSerial); err != nil {
+		return fmt.Errorf("failed to delete duplicate device: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("commit tx: %w", err)
+	}
+	committed = true
+	return nil
+}
+
+// mergeDeviceFields applies the duplicate's user data and locks to the
+// canonical device. A field the duplicate locked is copied along with its
+// lock so the pinned value survives the merge.
+func mergeDeviceFields(to, from *Device) {
+	locked := make(map[string]bool, len(to.LockedFields))
+	for _, lf := range to.LockedFields {
+		locked[strings.ToLower(lf.Field)] = true
+	}
+	for _, lf := range from.LockedFields {
+		field := strings.ToLower(lf.Field)
+		if locked[field] {
+			continue
+		}
+		locked[field] = true
+		to.LockedFields = append(to.LockedFields, lf)
+		if src, dst := lockableField(from, field), lockableField(to, field); src != nil && dst != nil {
+			*dst = *src
+		}
+	}
+
+	for _, field := range []string{"asset_number", "location", "description", "web_ui_url"} {
+		if dst := lockableField(to, field); *dst == "" {
+			*dst = *lockableField(from, field)
+		}
+	}
+	if to.MACAddress == "" {
+		to.MACAddress = from.MACAddress
+	}
+	if to.InitialPageCount == 0 {
+		to.InitialPageCount = from.InitialPageCount
+	}
+	if !from.FirstSeen.IsZero() && (to.FirstSeen.IsZero() || from.FirstSeen.Before(to.FirstSeen)) {
+		to.FirstSeen = from.FirstSeen
+	}
+	if !from.CreatedAt.IsZero() && (to.CreatedAt.IsZero() || from.CreatedAt.Before(to.CreatedAt)) {
+		to.CreatedAt = from.CreatedAt
+	}
+	to.IsSaved = to.IsSaved || from.IsSaved
+	to.Visible = to.Visible || from.Visible
+}
+
+// lockableField returns the string field a lock name refers to, or nil.
+func lockableField(d *Device, field string) *string {
+	switch field {
+	case "ip":
+		return &d.IP
+	case "manufacturer":
+		return &d.Manufacturer
+	case "model":
+		return &d.Model
+	case "hostname":
+		return &d.Hostname
+	case "asset_number":
+		return &d.AssetNumber
+	case "location":
+		return &d.Location
+	case "description":
+		return &d.Description
+	case "web_ui_url":
+		return &d.WebUIURL
+	}
+	return nil
+}
@@ -0,0 +1,80 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestMergeDeviceMovesHistoryAndLocks(t *testing.T) {
+	store, err := NewSQLiteStore(":memory:")
+	if err != nil {
+		t.Fatalf("NewSQLiteStore: %v", err)
+	}
+	defer store.Close()
+	ctx := context.Background()
+
+	pseudo := newFullTestDevice("10.0.0.5", "10.0.0.5", "HP", "", true, true)
+	pseudo.Location = "Lobby"
+	pseudo.LockedFields = []FieldLock{{Field: "location", Reason: "user_locked", LockedAt: time.Now()}}
+	pseudo.FirstSeen = time.Now().Add(-72 * time.Hour)
+	real := newFullTestDevice("CNB1234567", "10.0.0.5", "HP", "LaserJet M404", false, true)
+	real.Location = "Unassigned"
+	real.AssetNumber = "A-100"
+	for _, d := range []*Device{pseudo, real} {
+		if err := store.Create(ctx, d); err != nil {
+			t.Fatalf("Create %s: %v", d.Serial, err)
+		}
+	}
+	old := newTestMetrics("10.0.0.5", 1000)
+	old.Timestamp = time.Now().Add(-48 * time.Hour)
+	if err := store.SaveMetricsSnapshot(ctx, old); err != nil {
+		t.Fatalf("SaveMetricsSnapshot: %v", err)
+	}
+	if err := store.SaveMetricsSnapshot(ctx, newTestMetrics("CNB1234567", 1200)); err != nil {
+		t.Fatalf("SaveMetricsSnapshot: %v", err)
+	}
+	if err := store.AddScanHistory(ctx, &ScanSnapshot{Serial: "10.0.0.5", IP: "10.0.0.5"}); err != nil {
+		t.Fatalf("AddScanHistory: %v", err)
+	}
+
+	if err := store.MergeDevice(ctx, "10.0.0.5", "10.0.0.5"); err == nil {
+		t.Fatal("expected merging a device into itself to fail")
+	}
+	if err := store.MergeDevice(ctx, "missing", "CNB1234567"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected ErrNotFound, got %v", err)
+	}
+	if err := store.MergeDevice(ctx, "10.0.0.5", "CNB1234567"); err != nil {
+		t.Fatalf("MergeDevice: %v", err)
+	}
+
+	if _, err := store.Get(ctx, "10.0.0.5"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected duplicate to be deleted, got %v", err)
+	}
+	merged, err := store.Get(ctx, "CNB1234567")
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	if merged.Location != "Lobby" || merged.AssetNumber != "A-100" || merged.Model != "LaserJet M404" {
+		t.Fatalf("unexpected merged fields: %+v", merged.Device)
+	}
+	if !merged.IsSaved || len(merged.LockedFields) != 1 || merged.LockedFields[0].Field != "location" {
+		t.Fatalf("expected saved flag and lock to carry over: saved=%v locks=%+v", merged.IsSaved, merged.LockedFields)
+	}
+	if !merged.FirstSeen.Before(time.Now().Add(-71 * time.Hour)) {
+		t.Fatalf("expected earliest first_seen, got %v", merged.FirstSeen)
+	}
+
+	history, err := store.GetTieredMetricsHistory(ctx, "CNB1234567", time.Now().Add(-72*time.Hour), time.Now().Add(time.Minute))
+	if err != nil {
+		t.Fatalf("GetTieredMetricsHistory: %v", err)
+	}
+	if len(history) != 2 {
+		t.Fatalf("expected 2 metrics rows after merge, got %d", len(history))
+	}
+	scans, err := store.GetScanHistory(ctx, "CNB1234567", 10)
+	if err != nil || len(scans) != 1 {
+		t.Fatalf("expected scan history to move, got %d (%v)", len(scans), err)
+	}
+}
@@ -0,0 +1,148 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// Reasons a device watch ended.
+const (
+	DeviceWatchExpired = "expired"
+	DeviceWatchStopped = "stopped"
+)
+
+// DeviceWatch is a temporary high-frequency polling session for one device,
+// started to catch an intermittent problem. Raw metrics collected while the
+// watch ran are kept past normal raw retention, for as long as the watch
+// record itself.
+type DeviceWatch struct {
+	ID              int64      `json:"id"`
+	Serial          string     `json:"serial"`
+	IntervalSeconds int        `json:"interval_seconds"`
+	StartedAt       time.Time  `json:"started_at"`
+	ExpiresAt       time.Time  `json:"expires_at"`
+	EndedAt         *time.Time `json:"ended_at,omitempty"`
+	EndReason       string     `json:"end_reason,omitempty"`
+	Samples         int        `json:"samples"`
+	Failures        int        `json:"failures"`
+	LastError       string     `json:"last_error,omitempty"`
+}
+
+// DeviceWatchStore defines operations for device watch sessions.
+type DeviceWatchStore interface {
+	// AddDeviceWatch stores a new watch, setting watch.ID
+	AddDeviceWatch(ctx context.Context, watch *DeviceWatch) error
+
+	// UpdateDeviceWatch saves the counters, last error and end of a watch
+	UpdateDeviceWatch(ctx context.Context, watch *DeviceWatch) error
+
+	// ListDeviceWatches returns watches for a device (all devices if serial is empty), newest first
+	ListDeviceWatches(ctx context.Context, serial string, limit int) ([]*DeviceWatch, error)
+
+	// ListOpenDeviceWatches returns watches that have not ended, oldest first
+	ListOpenDeviceWatches(ctx context.Context) ([]*DeviceWatch, error)
+}
+
+const deviceWatchSelect = `SELECT id, serial, interval_seconds, started_at, expires_at, ended_at, end_reason,
+	samples, failures, last_error FROM device_watches`
+
+// watchTime formats a watch boundary like metrics_raw timestamps, so the two
+// compare directly in SQL.
+func watchTime(t time.Time) string {
+	return t.UTC().Format(time.RFC3339Nano)
+}
+
+// AddDeviceWatch stores a new watch, setting watch.ID.
+func (s *SQLiteStore) AddDeviceWatch(ctx context.Context, watch *DeviceWatch) error {
+	if watch == nil || watch.Serial == "" {
+		return ErrInvalidSerial
+	}
+	result, err := s.db.ExecContext(ctx, `
+		INSERT INTO device_watches (serial, interval_seconds, started_at, expires_at, samples, failures)
+		VALUES (?, ?, ?, ?, ?, ?)
+	`, watch.Serial, watch.IntervalSeconds, watchTime(watch.StartedAt), watchTime(watch.ExpiresAt),
+		watch.Samples, watch.Failures)
+	if err != nil {
+		return fmt.Errorf("failed to add device watch: %w", err)
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return fmt.Errorf("failed to get device watch id: %w", err)
+	}
+	watch.ID = id
+	return nil
+}
+
+// UpdateDeviceWatch saves the counters, last error and end of a watch.
+func (s *SQLiteStore) UpdateDeviceWatch(ctx context.Context, watch *DeviceWatch) error {
+	var ended interface{}
+	if watch.EndedAt != nil {
+		ended = watchTime(*watch.EndedAt)
+	}
+	result, err := s.db.ExecContext(ctx, `
+		UPDATE device_watches
+		SET expires_at = ?, ended_at = ?, end_reason = ?, samples = ?, failures = ?, last_error = ?
+		WHERE id = ?
+	`, watchTime(watch.ExpiresAt), ended, watch.EndReason, watch.Samples, watch.Failures, watch.LastError, watch.ID)
+	if err != nil {
+		return fmt.Errorf("failed to update device watch: %w", err)
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return fmt.Errorf("device watch %d not found", watch.ID)
+	}
+	return nil
+}
+
+// ListDeviceWatches returns watches for a device (all devices if serial is
+// empty), newest first.
+func (s *SQLiteStore) ListDeviceWatches(ctx context.Context, serial string, limit int) ([]*DeviceWatch, error) {
+	query := deviceWatchSelect
+	var args []interface{}
+	if serial != "" {
+		query += " WHERE serial = ?"
+		args = append(args, serial)
+	}
+	query += " ORDER BY id DESC"
+	if limit > 0 {
+		query += " LIMIT ?"
+		args = append(args, limit)
+	}
+	return s.queryDeviceWatches(ctx, query, args...)
+}
+
+// ListOpenDeviceWatches returns watches that have not ended, oldest first.
+func (s *SQLiteStore) ListOpenDeviceWatches(ctx context.Context) ([]*DeviceWatch, error) {
+	return s.queryDeviceWatches(ctx, deviceWatchSelect+" WHERE ended_at IS NULL ORDER BY id")
+}
+
+func (s *SQLiteStore) queryDeviceWatches(ctx context.Context, query string, args ...interface{}) ([]*DeviceWatch, error) {
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query device watches: %w", err)
+	}
+	defer rows.Close()
+
+	var watches []*DeviceWatch
+	for rows.Next() {
+		var w DeviceWatch
+		var started, expires string
+		var ended, reason, lastErr sql.NullString
+		if err := rows.Scan(&w.ID, &w.Serial, &w.IntervalSeconds, &started, &expires, &ended, &reason,
+			&w.Samples, &w.Failures, &lastErr); err != nil {
+			return nil, fmt.Errorf("failed to scan device watch: %w", err)
+		}
+		w.StartedAt, _ = time.Parse(time.RFC3339Nano, started)
+		w.ExpiresAt, _ = time.Parse(time.RFC3339Nano, expires)
+		if ended.Valid {
+			if t, err := time.Parse(time.RFC3339Nano, ended.String); err == nil {
+				w.EndedAt = &t
+			}
+		}
+		w.EndReason = reason.String
+		w.LastError = lastErr.String
+		watches = append(watches, &w)
+	}
+	return watches, rows.Err()
+}
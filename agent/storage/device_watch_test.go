@@ -0,0 +1,74 @@
+package storage
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestSQLiteStore_DeviceWatches(t *testing.T) {
+	store, err := NewSQLiteStore(":memory:")
+	if err != nil {
+		t.Fatalf("failed to create store: %v", err)
+	}
+	defer store.Close()
+	ctx := context.Background()
+
+	if err := store.Create(ctx, newTestDevice("W1", "10.0.0.5", true, true)); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+
+	// A watch ten days ago, a day long; raw samples inside and outside it
+	started := time.Now().Add(-10 * 24 * time.Hour)
+	watch := &DeviceWatch{Serial: "W1", IntervalSeconds: 30, StartedAt: started, ExpiresAt: started.Add(24 * time.Hour)}
+	if err := store.AddDeviceWatch(ctx, watch); err != nil {
+		t.Fatalf("AddDeviceWatch: %v", err)
+	}
+	for i, ts := range []time.Time{started.Add(-time.Hour), started.Add(time.Minute), started.Add(2 * time.Minute)} {
+		m := newTestMetrics("W1", 100+i)
+		m.Timestamp = ts
+		if err := store.SaveMetricsSnapshot(ctx, m); err != nil {
+			t.Fatalf("SaveMetricsSnapshot: %v", err)
+		}
+	}
+
+	open, err := store.ListOpenDeviceWatches(ctx)
+	if err != nil || len(open) != 1 || open[0].ID != watch.ID {
+		t.Fatalf("ListOpenDeviceWatches = %v, %v", open, err)
+	}
+
+	ended := started.Add(5 * time.Minute)
+	watch.EndedAt, watch.EndReason = &ended, DeviceWatchStopped
+	watch.Samples, watch.Failures, watch.LastError = 10, 1, "timeout"
+	if err := store.UpdateDeviceWatch(ctx, watch); err != nil {
+		t.Fatalf("UpdateDeviceWatch: %v", err)
+	}
+	list, err := store.ListDeviceWatches(ctx, "W1", 10)
+	if err != nil || len(list) != 1 {
+		t.Fatalf("ListDeviceWatches = %v, %v", list, err)
+	}
+	got := list[0]
+	if got.EndedAt == nil || !got.EndedAt.Equal(ended) || got.Samples != 10 || got.LastError != "timeout" || got.EndReason != DeviceWatchStopped {
+		t.Errorf("watch after update = %+v", got)
+	}
+	if open, _ := store.ListOpenDeviceWatches(ctx); len(open) != 0 {
+		t.Errorf("ended watch still open")
+	}
+
+	// Raw cleanup keeps the samples taken during the watch
+	results, err := store.CleanupOldTieredMetrics(ctx, 7, 30, 365)
+	if err != nil {
+		t.Fatalf("CleanupOldTieredMetrics: %v", err)
+	}
+	if results["raw"] != 1 || results["watches"] != 0 {
+		t.Errorf("cleanup results = %v, want 1 raw row and no watches deleted", results)
+	}
+
+	// Once the watch ages out, its samples follow
+	if results, err = store.CleanupOldTieredMetrics(ctx, 7, 5, 365); err != nil {
+		t.Fatalf("CleanupOldTieredMetrics: %v", err)
+	}
+	if results["raw"] != 2 || results["watches"] != 1 {
+		t.Errorf("cleanup results = %v, want 2 raw rows and 1 watch deleted", results)
+	}
+}
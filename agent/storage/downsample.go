@@ -5,6 +5,7 @@ import (
 	"database/sql"
 	"encoding/json"
 	"fmt"
+	"sort"
 	"time"
 )
 
@@ -421,10 +422,27 @@ func (s *SQLiteStore) CleanupOldTieredMetrics(ctx context.Context, rawRetentionD
 	now := time.Now()
 	results := make(map[string]int)
 
+	// Device watches are kept as long as hourly data; until then the raw
+	// samples taken during a watch are exempt from raw cleanup
+	hourlyCutoff := now.AddDate(0, 0, -hourlyRetentionDays)
+	hourlyCutoffStr := hourlyCutoff.UTC().Format(time.RFC3339Nano)
+	watchResult, err := s.db.ExecContext(ctx,
+		"DELETE FROM device_watches WHERE julianday(COALESCE(ended_at, expires_at)) < julianday(?)", hourlyCutoffStr)
+	if err != nil {
+		return results, fmt.Errorf("failed to cleanup device watches: %w", err)
+	}
+	watchCount, _ := watchResult.RowsAffected()
+	results["watches"] = int(watchCount)
+
 	// Cleanup raw metrics (default 7 days)
 	rawCutoff := now.AddDate(0, 0, -rawRetentionDays)
 	rawCutoffStr := rawCutoff.UTC().Format(time.RFC3339Nano)
-	rawResult, err := s.db.ExecContext(ctx, "DELETE FROM metrics_raw WHERE timestamp < ?", rawCutoffStr)
+	rawResult, err := s.db.ExecContext(ctx, `
+		DELETE FROM metrics_raw WHERE timestamp < ? AND NOT EXISTS (
+			SELECT 1 FROM device_watches w
+			WHERE w.serial = metrics_raw.serial
+				AND julianday(metrics_raw.timestamp) BETWEEN julianday(w.started_at) AND julianday(COALESCE(w.ended_at, w.expires_at))
+		)`, rawCutoffStr)
 	if err != nil {
 		return results, fmt.Errorf("failed to cleanup raw metrics: %w", err)
 	}
@@ -432,8 +450,6 @@ func (s *SQLiteStore) CleanupOldTieredMetrics(ctx context.Context, rawRetentionD
 	results["raw"] = int(rawCount)
 
 	// Cleanup hourly metrics (default 30 days)
-	hourlyCutoff := now.AddDate(0, 0, -hourlyRetentionDays)
-	hourlyCutoffStr := hourlyCutoff.UTC().Format(time.RFC3339Nano)
 	hourlyResult, err := s.db.ExecContext(ctx, "DELETE FROM metrics_hourly WHERE hour_start < ?", hourlyCutoffStr)
 	if err != nil {
 		return results, fmt.Errorf("failed to cleanup hourly metrics: %w", err)
@@ -459,6 +475,7 @@ func (s *SQLiteStore) CleanupOldTieredMetrics(ctx context.Context, rawRetentionD
 			"raw_deleted", rawCount,
 			"hourly_deleted", hourlyCount,
 			"daily_deleted", dailyCount,
+			"watches_deleted", watchCount,
 			"raw_retention_days", rawRetentionDays,
 			"hourly_retention_days", hourlyRetentionDays,
 			"daily_retention_days", dailyRetentionDays,
@@ -663,11 +680,13 @@ func (s *SQLiteStore) GetTieredMetricsHistory(ctx context.Context, serial string
 
 	var snapshots []*MetricsSnapshot
 
-	// Determine which tiers to query based on time range
-	needRaw := since.After(sevenDaysAgo) || until.After(sevenDaysAgo)
-	needHourly := (since.Before(sevenDaysAgo) && since.After(thirtyDaysAgo)) || (until.Before(sevenDaysAgo) && until.After(thirtyDaysAgo))
-	needDaily := (since.Before(thirtyDaysAgo) && since.After(oneYearAgo)) || (until.Before(thirtyDaysAgo) && until.After(oneYearAgo))
-	needMonthly := since.Before(oneYearAgo) || until.Before(oneYearAgo)
+	// Determine which tiers to query: every tier whose retention window
+	// overlaps [since, until], so ranges spanning several tiers (e.g. the
+	// last 31 days) also include the tiers in between.
+	needRaw := until.After(sevenDaysAgo)
+	needHourly := since.Before(sevenDaysAgo) && until.After(thirtyDaysAgo)
+	needDaily := since.Before(thirtyDaysAgo) && until.After(oneYearAgo)
+	needMonthly := since.Before(oneYearAgo)
 
 	// Query raw metrics (last 7 days)
 	if needRaw {
@@ -821,5 +840,10 @@ func (s *SQLiteStore) GetTieredMetricsHistory(ctx context.Context, serial string
 		}
 	}
 
+	// Tiers are queried newest-first; return a single chronological series
+	sort.SliceStable(snapshots, func(i, j int) bool {
+		return snapshots[i].Timestamp.Before(snapshots[j].Timestamp)
+	})
+
 	return snapshots, nil
 }
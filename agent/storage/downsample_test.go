@@ -319,3 +319,52 @@ func TestSQLiteStore_DownsampleRawToHourly_RollsBackOnInsertFailure(t *testing.T
 		t.Fatalf("expected transaction rollback (0 hourly rows), got %d", rows)
 	}
 }
+
+func TestSQLiteStore_GetTieredMetricsHistory_SpansTiers(t *testing.T) {
+	store, err := NewSQLiteStore(":memory:")
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	defer store.Close()
+
+	ctx := context.Background()
+	serial := "TEST_METRICS_SPAN"
+	now := time.Now().UTC()
+
+	if err := store.Create(ctx, newFullTestDevice(serial, "192.168.1.50", "HP", "LaserJet", false, true)); err != nil {
+		t.Fatalf("Failed to create device: %v", err)
+	}
+	if _, err := store.db.ExecContext(ctx,
+		`INSERT INTO metrics_raw (serial, timestamp, page_count, color_pages, mono_pages, scan_count, toner_levels) VALUES (?, ?, ?, 0, 0, 0, '{}')`,
+		serial, now.Add(-time.Hour).Format(time.RFC3339Nano), 300,
+	); err != nil {
+		t.Fatalf("Failed to insert raw: %v", err)
+	}
+	if _, err := store.db.ExecContext(ctx,
+		`INSERT INTO metrics_hourly (serial, hour_start, sample_count, page_count_min, page_count_max, page_count_avg, color_pages_min, color_pages_max, color_pages_avg, mono_pages_min, mono_pages_max, mono_pages_avg, scan_count_min, scan_count_max, scan_count_avg, toner_levels_avg) VALUES (?, ?, 1, ?, ?, ?, 0, 0, 0, 0, 0, 0, 0, 0, 0, '{}')`,
+		serial, now.AddDate(0, 0, -14).Format(time.RFC3339Nano), 200, 200, 200,
+	); err != nil {
+		t.Fatalf("Failed to insert hourly: %v", err)
+	}
+	if _, err := store.db.ExecContext(ctx,
+		`INSERT INTO metrics_daily (serial, day_start, sample_count, page_count_min, page_count_max, page_count_avg, color_pages_min, color_pages_max, color_pages_avg, mono_pages_min, mono_pages_max, mono_pages_avg, scan_count_min, scan_count_max, scan_count_avg, toner_levels_avg) VALUES (?, ?, 1, ?, ?, ?, 0, 0, 0, 0, 0, 0, 0, 0, 0, '{}')`,
+		serial, now.AddDate(0, 0, -31).Format(time.RFC3339Nano), 100, 100, 100,
+	); err != nil {
+		t.Fatalf("Failed to insert daily: %v", err)
+	}
+
+	// A range from the daily tier to now must include the hourly tier in between
+	got, err := store.GetTieredMetricsHistory(ctx, serial, now.AddDate(0, 0, -32), now)
+	if err != nil {
+		t.Fatalf("GetTieredMetricsHistory returned error: %v", err)
+	}
+	if len(got) != 3 {
+		t.Fatalf("Expected 3 snapshots across tiers, got %d", len(got))
+	}
+	wantTiers := []string{"daily", "hourly", "raw"}
+	for i, snap := range got {
+		if snap.Tier != wantTiers[i] {
+			t.Fatalf("Expected chronological tiers %v, got %s at %d", wantTiers, snap.Tier, i)
+		}
+	}
+}
@@ -0,0 +1,373 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing/fstest"
+	"time"
+
+	commonutil "printmaster/common/util"
+
+	"modernc.org/sqlite"
+	sqlitevfs "modernc.org/sqlite/vfs"
+)
+
+// Encryption at rest
+//
+// SQLCipher requires cgo, which the agent avoids so it stays a single static
+// binary on every platform. Instead, when an encryption key is configured,
+// file-backed stores run on an in-memory SQLite database (the memdb VFS,
+// shared by every pooled connection) and persist it as an AES-256-GCM sealed
+// image next to the original path (devices.db -> devices.db.enc). The image
+// is rewritten atomically after every committed write (see encryption_conn.go)
+// and on Close, so plaintext inventory never reaches the disk. The whole
+// database lives in RAM; sealing briefly needs two more copies of it.
+
+// EncryptedFileSuffix is appended to a database path to name its sealed image.
+const EncryptedFileSuffix = ".enc"
+
+var (
+	encryptionMu  sync.RWMutex
+	encryptionKey []byte
+	memdbSeq      atomic.Uint64
+)
+
+// SetEncryptionKey enables encryption at rest for stores opened afterwards.
+// The key must be 32 bytes (AES-256). A nil key disables encryption.
+func SetEncryptionKey(key []byte) error {
+	if key != nil && len(key) != 32 {
+		return fmt.Errorf("database encryption key must be 32 bytes, got %d", len(key))
+	}
+	encryptionMu.Lock()
+	defer encryptionMu.Unlock()
+	encryptionKey = key
+	return nil
+}
+
+func currentEncryptionKey() []byte {
+	encryptionMu.RLock()
+	defer encryptionMu.RUnlock()
+	return encryptionKey
+}
+
+// EncryptedPath returns the sealed image path used for dbPath.
+func EncryptedPath(dbPath string) string {
+	return dbPath + EncryptedFileSuffix
+}
+
+// encryptedDB keeps an in-memory database alive and mirrors it to a sealed
+// image on disk.
+type encryptedDB struct {
+	path   string    // sealed image on disk
+	key    []byte    // AES-256 key
+	anchor *sql.Conn // pinned connection; the memdb is freed when the last one closes
+	// readOnly images are never sealed back to disk
+	readOnly bool
+
+	mu          sync.Mutex
+	lastVersion int64         // PRAGMA data_version at the last flush (-1 = never flushed)
+	dirty       chan struct{} // signalled after each committed write
+	stop        chan struct{}
+	done        chan struct{}
+	closeOnce   sync.Once
+}
+
+// openSQLite opens dbPath, transparently using the encrypted backend when a
+// key is configured. enc is nil for plaintext and in-memory databases. A
+// sealed image without a key is an error rather than a fresh plaintext store,
+// so a lost key cannot silently reset the agent.
+func openSQLite(dbPath string) (db *sql.DB, enc *encryptedDB, err error) {
+	key := currentEncryptionKey()
+	if dbPath == "" || dbPath == ":memory:" {
+		db, err = sql.Open("sqlite", dbPath)
+		return db, nil, err
+	}
+	if key == nil {
+		if _, err := os.Stat(EncryptedPath(dbPath)); err == nil {
+			return nil, nil, fmt.Errorf("encrypted database %s exists but no database encryption key is loaded; "+
+				"enable [database_encryption] with the key it was sealed with, or move the file away to start with an empty database", EncryptedPath(dbPath))
+		}
+		db, err = sql.Open("sqlite", dbPath)
+		return db, nil, err
+	}
+	return openEncrypted(dbPath, key)
+}
+
+// openEncrypted loads the sealed image for dbPath into a shared in-memory
+// database. An existing plaintext database at dbPath is migrated when there is
+// no sealed image yet: its contents are loaded, sealed, and the plaintext
+// files are removed. When both exist the plaintext may hold newer data (for
+// example, written while encryption was off), so opening fails instead of
+// discarding either copy.
+func openEncrypted(dbPath string, key []byte) (*sql.DB, *encryptedDB, error) {
+	uri := fmt.Sprintf("file:/printmaster-%d-%s?vfs=memdb", memdbSeq.Add(1), filepath.Base(dbPath))
+	e := &encryptedDB{
+		path:        EncryptedPath(dbPath),
+		key:         key,
+		lastVersion: -1,
+		dirty:       make(chan struct{}, 1),
+		stop:        make(chan struct{}),
+		done:        make(chan struct{}),
+	}
+	db := sql.OpenDB(&sealingConnector{driver: &sqlite.Driver{}, dsn: uri, onCommit: e.markDirty})
+	anchor, err := db.Conn(context.Background())
+	if err != nil {
+		db.Close()
+		return nil, nil, fmt.Errorf("failed to open encrypted database: %w", err)
+	}
+	e.anchor = anchor
+	fail := func(err error) (*sql.DB, *encryptedDB, error) {
+		anchor.Close()
+		db.Close()
+		return nil, nil, err
+	}
+
+	migrated := false
+	sealed, err := os.ReadFile(e.path)
+	switch {
+	case err == nil:
+		if hasPlaintextData(dbPath) {
+			return fail(fmt.Errorf("both %s and the plaintext database %s exist; the plaintext copy may be newer "+
+				"(written while encryption was off), so remove the one you do not want to keep", e.path, dbPath))
+		}
+		image, err := commonutil.DecryptBytes(key, sealed)
+		if err != nil {
+			return fail(fmt.Errorf("failed to decrypt %s (wrong key?): %w", e.path, err))
+		}
+		if err := e.restoreImage(image); err != nil {
+			return fail(fmt.Errorf("failed to load %s: %w", e.path, err))
+		}
+	case !os.IsNotExist(err):
+		return fail(fmt.Errorf("failed to read %s: %w", e.path, err))
+	default:
+		if hasPlaintextData(dbPath) {
+			if err := e.restorePlaintext(dbPath); err != nil {
+				return fail(fmt.Errorf("failed to migrate plaintext database %s: %w", dbPath, err))
+			}
+			migrated = true
+		}
+	}
+
+	if migrated {
+		// Seal before touching the plaintext so a crash cannot lose data
+		if err := e.flush(true); err != nil {
+			return fail(fmt.Errorf("failed to write %s: %w", e.path, err))
+		}
+		if storageLogger != nil {
+			storageLogger.Info("Migrated plaintext database to encrypted storage", "from", dbPath, "to", e.path)
+		}
+	}
+	// Either the plaintext was just sealed, or only empty placeholders remain
+	removePlaintextDatabase(dbPath)
+
+	go e.loop()
+	return db, e, nil
+}
+
+// openSealedReadOnly loads the sealed image for dbPath into a private memdb
+// without starting the flush loop, so nothing is ever written back. It never
+// looks at or removes a plaintext database.
+func openSealedReadOnly(dbPath string, key []byte) (*sql.DB, *encryptedDB, error) {
+	sealed, err := os.ReadFile(EncryptedPath(dbPath))
+	if err != nil {
+		return nil, nil, err
+	}
+	image, err := commonutil.DecryptBytes(key, sealed)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to decrypt %s (wrong key?): %w", EncryptedPath(dbPath), err)
+	}
+	uri := fmt.Sprintf("file:/printmaster-ro-%d-%s?vfs=memdb", memdbSeq.Add(1), filepath.Base(dbPath))
+	db, err := sql.Open("sqlite", uri)
+	if err != nil {
+		return nil, nil, err
+	}
+	anchor, err := db.Conn(context.Background())
+	if err != nil {
+		db.Close()
+		return nil, nil, fmt.Errorf("failed to open encrypted database: %w", err)
+	}
+	e := &encryptedDB{path: EncryptedPath(dbPath), anchor: anchor, readOnly: true,
+		stop: make(chan struct{}), done: make(chan struct{})}
+	close(e.done)
+	if err := e.restoreImage(image); err != nil {
+		anchor.Close()
+		db.Close()
+		return nil, nil, fmt.Errorf("failed to load %s: %w", e.path, err)
+	}
+	return db, e, nil
+}
+
+// restoreImage copies a decrypted database image into the memdb. The image is
+// served from memory through a read-only VFS, so it never touches the disk.
+func (e *encryptedDB) restoreImage(image []byte) error {
+	name, fsys, err := sqlitevfs.New(fstest.MapFS{"image.db": &fstest.MapFile{Data: image}})
+	if err != nil {
+		return err
+	}
+	defer fsys.Close()
+	return e.restoreFrom("file:image.db?vfs=" + name)
+}
+
+// restorePlaintext copies an existing plaintext database into the memdb. WAL
+// mode is switched off first because memdb cannot restore from a WAL source.
+func (e *encryptedDB) restorePlaintext(dbPath string) error {
+	src, err := sql.Open("sqlite", dbPath)
+	if err != nil {
+		return err
+	}
+	if _, err := src.Exec("PRAGMA journal_mode = DELETE"); err != nil {
+		src.Close()
+		return err
+	}
+	if err := src.Close(); err != nil {
+		return err
+	}
+	return e.restoreFrom(dbPath)
+}
+
+func (e *encryptedDB) restoreFrom(srcURI string) error {
+	return e.anchor.Raw(func(driverConn interface{}) error {
+		conn, ok := driverConnOf(driverConn).(interface {
+			NewRestore(string) (*sqlite.Backup, error)
+		})
+		if !ok {
+			return errors.New("sqlite driver does not support restore")
+		}
+		bk, err := conn.NewRestore(srcURI)
+		if err != nil {
+			return err
+		}
+		for more := true; more; {
+			if more, err = bk.Step(-1); err != nil {
+				bk.Finish()
+				return err
+			}
+		}
+		return bk.Finish()
+	})
+}
+
+// flush seals the database to disk when it changed since the last flush, or
+// unconditionally when force is set.
+func (e *encryptedDB) flush(force bool) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	ctx := context.Background()
+	var version, pages int64
+	if err := e.anchor.QueryRowContext(ctx, "PRAGMA data_version").Scan(&version); err != nil {
+		return err
+	}
+	if !force && version == e.lastVersion {
+		return nil
+	}
+	if err := e.anchor.QueryRowContext(ctx, "PRAGMA page_count").Scan(&pages); err != nil {
+		return err
+	}
+	if pages == 0 {
+		return nil // Nothing created yet
+	}
+
+	var image []byte
+	err := e.anchor.Raw(func(driverConn interface{}) error {
+		conn, ok := driverConnOf(driverConn).(interface{ Serialize() ([]byte, error) })
+		if !ok {
+			return errors.New("sqlite driver does not support serialize")
+		}
+		var err error
+		image, err = conn.Serialize()
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	sealed, err := commonutil.EncryptBytes(e.key, image)
+	if err != nil {
+		return err
+	}
+	if err := commonutil.WriteFileAtomic(e.path, sealed, 0o600); err != nil {
+		return err
+	}
+	e.lastVersion = version
+	return nil
+}
+
+// markDirty schedules a seal. Commits that arrive while one is being written
+// are coalesced into the next.
+func (e *encryptedDB) markDirty() {
+	select {
+	case e.dirty <- struct{}{}:
+	default:
+	}
+}
+
+func (e *encryptedDB) loop() {
+	defer close(e.done)
+	for {
+		select {
+		case <-e.stop:
+			return
+		case <-e.dirty:
+			if err := e.flush(false); err != nil && storageLogger != nil {
+				storageLogger.WarnRateLimited("encrypted_db_flush", 5*time.Minute,
+					"Failed to write encrypted database", "path", e.path, "error", err)
+			}
+		}
+	}
+}
+
+// Close writes a final image and releases the in-memory database. The owning
+// *sql.DB must be closed afterwards.
+func (e *encryptedDB) Close() error {
+	var err error
+	e.closeOnce.Do(func() {
+		close(e.stop)
+		<-e.done
+		if !e.readOnly {
+			err = e.flush(false)
+		}
+		if cerr := e.anchor.Close(); err == nil {
+			err = cerr
+		}
+	})
+	return err
+}
+
+// hasPlaintextData reports whether a plaintext database at dbPath, or its WAL,
+// holds any data.
+func hasPlaintextData(dbPath string) bool {
+	for _, p := range []string{dbPath, dbPath + "-wal"} {
+		if fi, err := os.Stat(p); err == nil && !fi.IsDir() && fi.Size() > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// removePlaintextDatabase deletes a plaintext database and its WAL files after
+// migration (or an empty placeholder). Contents are overwritten first so the
+// data is not trivially recoverable from the freed blocks.
+func removePlaintextDatabase(dbPath string) {
+	for _, p := range []string{dbPath, dbPath + "-wal", dbPath + "-shm", dbPath + "-journal"} {
+		fi, err := os.Stat(p)
+		if err != nil || fi.IsDir() {
+			continue
+		}
+		if fi.Size() > 0 {
+			if f, err := os.OpenFile(p, os.O_WRONLY, 0); err == nil {
+				_, _ = f.Write(make([]byte, fi.Size()))
+				_ = f.Sync()
+				_ = f.Close()
+			}
+		}
+		if err := os.Remove(p); err != nil && storageLogger != nil {
+			storageLogger.Warn("Failed to remove plaintext database file", "path", p, "error", err)
+		}
+	}
+}
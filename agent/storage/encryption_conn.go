@@ -0,0 +1,157 @@
+package storage
+
+import (
+	"context"
+	"database/sql/driver"
+)
+
+// The SQLite driver has no commit hook, so encrypted stores open their memdb
+// through sealingConnector. Its connections report every write that commits
+// (an autocommit Exec or a transaction Commit) and the encryptedDB re-seals
+// the image right away instead of waiting for a timer.
+
+// sealingConnector opens driver connections that call onCommit after writes.
+type sealingConnector struct {
+	driver   driver.Driver
+	dsn      string
+	onCommit func()
+}
+
+func (c *sealingConnector) Connect(context.Context) (driver.Conn, error) {
+	conn, err := c.driver.Open(c.dsn)
+	if err != nil {
+		return nil, err
+	}
+	return &sealingConn{Conn: conn, onCommit: c.onCommit}, nil
+}
+
+func (c *sealingConnector) Driver() driver.Driver {
+	return c.driver
+}
+
+// sealingConn wraps a sqlite driver connection. database/sql uses a
+// connection from one goroutine at a time, so inTx needs no locking.
+type sealingConn struct {
+	driver.Conn
+	onCommit func()
+	inTx     bool
+}
+
+// committed reports a write outside an explicit transaction; writes inside one
+// are reported by sealingTx.Commit.
+func (c *sealingConn) committed() {
+	if !c.inTx {
+		c.onCommit()
+	}
+}
+
+func (c *sealingConn) Begin() (driver.Tx, error) {
+	return c.BeginTx(context.Background(), driver.TxOptions{})
+}
+
+func (c *sealingConn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	tx, err := c.Conn.(driver.ConnBeginTx).BeginTx(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+	c.inTx = true
+	return &sealingTx{Tx: tx, conn: c}, nil
+}
+
+func (c *sealingConn) Prepare(query string) (driver.Stmt, error) {
+	return c.PrepareContext(context.Background(), query)
+}
+
+func (c *sealingConn) PrepareContext(ctx context.Context, query string) (driver.Stmt, error) {
+	stmt, err := c.Conn.(driver.ConnPrepareContext).PrepareContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	return &sealingStmt{Stmt: stmt, conn: c}, nil
+}
+
+func (c *sealingConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	res, err := c.Conn.(driver.ExecerContext).ExecContext(ctx, query, args)
+	if err == nil {
+		c.committed()
+	}
+	return res, err
+}
+
+func (c *sealingConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	return c.Conn.(driver.QueryerContext).QueryContext(ctx, query, args)
+}
+
+func (c *sealingConn) Ping(ctx context.Context) error {
+	if p, ok := c.Conn.(driver.Pinger); ok {
+		return p.Ping(ctx)
+	}
+	return nil
+}
+
+func (c *sealingConn) ResetSession(ctx context.Context) error {
+	if r, ok := c.Conn.(driver.SessionResetter); ok {
+		return r.ResetSession(ctx)
+	}
+	return nil
+}
+
+func (c *sealingConn) IsValid() bool {
+	if v, ok := c.Conn.(driver.Validator); ok {
+		return v.IsValid()
+	}
+	return true
+}
+
+type sealingTx struct {
+	driver.Tx
+	conn *sealingConn
+}
+
+func (t *sealingTx) Commit() error {
+	t.conn.inTx = false
+	err := t.Tx.Commit()
+	if err == nil {
+		t.conn.onCommit()
+	}
+	return err
+}
+
+func (t *sealingTx) Rollback() error {
+	t.conn.inTx = false
+	return t.Tx.Rollback()
+}
+
+type sealingStmt struct {
+	driver.Stmt
+	conn *sealingConn
+}
+
+func (s *sealingStmt) Exec(args []driver.Value) (driver.Result, error) {
+	res, err := s.Stmt.Exec(args)
+	if err == nil {
+		s.conn.committed()
+	}
+	return res, err
+}
+
+func (s *sealingStmt) ExecContext(ctx context.Context, args []driver.NamedValue) (driver.Result, error) {
+	res, err := s.Stmt.(driver.StmtExecContext).ExecContext(ctx, args)
+	if err == nil {
+		s.conn.committed()
+	}
+	return res, err
+}
+
+func (s *sealingStmt) QueryContext(ctx context.Context, args []driver.NamedValue) (driver.Rows, error) {
+	return s.Stmt.(driver.StmtQueryContext).QueryContext(ctx, args)
+}
+
+// driverConnOf unwraps a sealingConn so sqlite-specific methods such as
+// Serialize and NewRestore stay reachable through sql.Conn.Raw.
+func driverConnOf(driverConn interface{}) interface{} {
+	if c, ok := driverConn.(*sealingConn); ok {
+		return c.Conn
+	}
+	return driverConn
+}
@@ -0,0 +1,275 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func useEncryptionKey(t *testing.T, key []byte) {
+	t.Helper()
+	if err := SetEncryptionKey(key); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = SetEncryptionKey(nil) })
+}
+
+func TestEncryptedAgentConfigRoundTrip(t *testing.T) {
+	key := bytes.Repeat([]byte{7}, 32)
+	useEncryptionKey(t, key)
+	dbPath := filepath.Join(t.TempDir(), "agent.db")
+
+	store, err := NewAgentConfigStore(dbPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := store.SetRanges("10.20.30.0/24"); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := os.Stat(dbPath); !os.IsNotExist(err) {
+		t.Fatalf("plaintext database should not exist, stat err = %v", err)
+	}
+	sealed, err := os.ReadFile(EncryptedPath(dbPath))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Contains(sealed, []byte("10.20.30.0/24")) || bytes.Contains(sealed, []byte("SQLite format")) {
+		t.Fatal("sealed image contains plaintext")
+	}
+
+	store, err = NewAgentConfigStore(dbPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+	if got, _ := store.GetRanges(); got != "10.20.30.0/24" {
+		t.Fatalf("ranges after reopen = %q", got)
+	}
+
+	useEncryptionKey(t, bytes.Repeat([]byte{8}, 32))
+	if _, err := NewAgentConfigStore(dbPath); err == nil || !strings.Contains(err.Error(), "decrypt") {
+		t.Fatalf("expected decrypt error with the wrong key, got %v", err)
+	}
+}
+
+func TestEncryptedSealsAfterCommit(t *testing.T) {
+	key := bytes.Repeat([]byte{5}, 32)
+	useEncryptionKey(t, key)
+	dbPath := filepath.Join(t.TempDir(), "agent.db")
+
+	store, err := NewAgentConfigStore(dbPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+	if err := store.SetRanges("10.9.0.0/24"); err != nil {
+		t.Fatal(err)
+	}
+
+	// The image on disk must catch up without Close, as it would before a crash
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		ro, err := OpenAgentConfigReadOnly(dbPath, key)
+		if err == nil {
+			got, _ := ro.GetRanges()
+			ro.Close()
+			if got == "10.9.0.0/24" {
+				return
+			}
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("committed write not sealed to disk (last err %v)", err)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
+
+func TestEncryptedMigratesPlaintextDatabase(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "agent.db")
+	plain, err := NewAgentConfigStore(dbPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := plain.SetConfigValue("site", "HQ"); err != nil {
+		t.Fatal(err)
+	}
+	plain.Close()
+
+	useEncryptionKey(t, bytes.Repeat([]byte{1}, 32))
+	store, err := NewAgentConfigStore(dbPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+
+	var site string
+	if err := store.GetConfigValue("site", &site); err != nil || site != "HQ" {
+		t.Fatalf("migrated value = %q (%v)", site, err)
+	}
+	for _, p := range []string{dbPath, dbPath + "-wal", dbPath + "-shm"} {
+		if _, err := os.Stat(p); !os.IsNotExist(err) {
+			t.Errorf("%s should be removed after migration", filepath.Base(p))
+		}
+	}
+	if _, err := os.Stat(EncryptedPath(dbPath)); err != nil {
+		t.Fatalf("sealed image not written during migration: %v", err)
+	}
+}
+
+func TestEncryptedRefusesPlaintextAlongsideSealedImage(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "agent.db")
+	useEncryptionKey(t, bytes.Repeat([]byte{4}, 32))
+	store, err := NewAgentConfigStore(dbPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := store.SetRanges("10.1.0.0/24"); err != nil {
+		t.Fatal(err)
+	}
+	store.Close()
+
+	// Without the key the sealed image must not be ignored
+	if err := SetEncryptionKey(nil); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewAgentConfigStore(dbPath); err == nil || !strings.Contains(err.Error(), "no database encryption key") {
+		t.Fatalf("expected missing key error, got %v", err)
+	}
+	if _, err := os.Stat(dbPath); !os.IsNotExist(err) {
+		t.Fatalf("plaintext database created without a key, stat err = %v", err)
+	}
+
+	// A plaintext database written while encryption was off must survive
+	if err := os.WriteFile(dbPath, []byte("newer plaintext"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	useEncryptionKey(t, bytes.Repeat([]byte{4}, 32))
+	if _, err := NewAgentConfigStore(dbPath); err == nil || !strings.Contains(err.Error(), "both") {
+		t.Fatalf("expected conflict error, got %v", err)
+	}
+	if data, err := os.ReadFile(dbPath); err != nil || string(data) != "newer plaintext" {
+		t.Fatalf("plaintext database modified: %q, %v", data, err)
+	}
+}
+
+func TestEncryptedDeviceStoreKeepsPlaintextOffDisk(t *testing.T) {
+	useEncryptionKey(t, bytes.Repeat([]byte{3}, 32))
+	oldLogger := storageLogger
+	SetLogger(&testLogger{})
+	defer SetLogger(oldLogger)
+	dir := t.TempDir()
+	dbPath := filepath.Join(dir, "devices.db")
+
+	store, err := NewSQLiteStore(dbPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Create(context.Background(), newTestDevice("SECRET-SERIAL", "10.0.0.9", true, true)); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, entry := range entries {
+		if !strings.HasPrefix(entry.Name(), "devices.db.enc") {
+			t.Errorf("unexpected file on disk: %s", entry.Name())
+			continue
+		}
+		data, _ := os.ReadFile(filepath.Join(dir, entry.Name()))
+		if bytes.Contains(data, []byte("SECRET-SERIAL")) {
+			t.Errorf("%s contains plaintext inventory", entry.Name())
+		}
+	}
+}
+
+func TestOpenAgentConfigReadOnly(t *testing.T) {
+	key := bytes.Repeat([]byte{7}, 32)
+	dir := t.TempDir()
+
+	if _, err := OpenAgentConfigReadOnly(filepath.Join(dir, "agent.db"), key); err != ErrNotFound {
+		t.Fatalf("missing database: err = %v, want ErrNotFound", err)
+	}
+
+	// Sealed image: readable with the key, never rewritten
+	useEncryptionKey(t, key)
+	sealedPath := filepath.Join(dir, "sealed", "agent.db")
+	if err := os.MkdirAll(filepath.Dir(sealedPath), 0o700); err != nil {
+		t.Fatal(err)
+	}
+	store, err := NewAgentConfigStore(sealedPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := store.SetRanges("10.1.0.0/24"); err != nil {
+		t.Fatal(err)
+	}
+	store.Close()
+	_ = SetEncryptionKey(nil)
+	before, err := os.ReadFile(EncryptedPath(sealedPath))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ro, err := OpenAgentConfigReadOnly(sealedPath, key)
+	if err != nil {
+		t.Fatalf("OpenAgentConfigReadOnly(sealed): %v", err)
+	}
+	if got, _ := ro.GetRanges(); got != "10.1.0.0/24" {
+		t.Errorf("sealed ranges = %q", got)
+	}
+	_ = ro.SetRanges("192.0.2.0/24")
+	if err := ro.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if after, _ := os.ReadFile(EncryptedPath(sealedPath)); !bytes.Equal(before, after) {
+		t.Error("read-only open rewrote the sealed image")
+	}
+	if _, err := OpenAgentConfigReadOnly(sealedPath, nil); err != ErrNotFound {
+		t.Errorf("sealed without key: err = %v, want ErrNotFound", err)
+	}
+
+	// Plaintext database with a key configured: read in place, not migrated
+	plainPath := filepath.Join(dir, "plain", "agent.db")
+	if err := os.MkdirAll(filepath.Dir(plainPath), 0o700); err != nil {
+		t.Fatal(err)
+	}
+	store, err = NewAgentConfigStore(plainPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := store.SetRanges("10.2.0.0/24"); err != nil {
+		t.Fatal(err)
+	}
+	store.Close()
+
+	ro, err = OpenAgentConfigReadOnly(plainPath, key)
+	if err != nil {
+		t.Fatalf("OpenAgentConfigReadOnly(plaintext): %v", err)
+	}
+	if got, _ := ro.GetRanges(); got != "10.2.0.0/24" {
+		t.Errorf("plaintext ranges = %q", got)
+	}
+	if err := ro.SetRanges("192.0.2.0/24"); err == nil {
+		t.Error("expected write to a read-only plaintext database to fail")
+	}
+	ro.Close()
+	if _, err := os.Stat(plainPath); err != nil {
+		t.Errorf("plaintext database removed: %v", err)
+	}
+	if _, err := os.Stat(EncryptedPath(plainPath)); !os.IsNotExist(err) {
+		t.Errorf("sealed image created for plaintext database, stat err = %v", err)
+	}
+}
@@ -0,0 +1,198 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"printmaster/common/meterread"
+)
+
+// MeterRead is a type alias for the common certified meter read.
+type MeterRead = meterread.Read
+
+// ErrMeterReadExists is returned when adding a meter read whose ID is already stored.
+var ErrMeterReadExists = errors.New("meter read already exists")
+
+// MeterReadStore defines operations for certified meter read storage. Reads
+// are immutable once stored; only their upload state changes.
+type MeterReadStore interface {
+	// AddMeterRead stores a new signed meter read
+	AddMeterRead(ctx context.Context, read *MeterRead) error
+
+	// ListMeterReads returns reads for a device (all devices if serial is empty), newest first
+	ListMeterReads(ctx context.Context, serial string, limit int) ([]*MeterRead, error)
+
+	// LatestMeterReadPeriod returns the period of the newest read with the given trigger ("" if none)
+	LatestMeterReadPeriod(ctx context.Context, serial, trigger string) (string, error)
+
+	// ListPendingMeterReads returns reads not yet uploaded to the server, oldest first
+	ListPendingMeterReads(ctx context.Context, limit int) ([]*MeterRead, error)
+
+	// MarkMeterReadsUploaded records that the given reads reached the server
+	MarkMeterReadsUploaded(ctx context.Context, ids []string, at time.Time) error
+
+	// QuarantineMeterRead stops uploading a read the server refused, keeping it with the reason
+	QuarantineMeterRead(ctx context.Context, id, reason string, at time.Time) error
+}
+
+// AddMeterRead stores a new signed meter read.
+func (s *SQLiteStore) AddMeterRead(ctx context.Context, read *MeterRead) error {
+	if read == nil || read.Serial == "" {
+		return ErrInvalidSerial
+	}
+	if read.ID == "" {
+		return fmt.Errorf("meter read id is required")
+	}
+	confirmation, err := json.Marshal(read.Confirmation)
+	if err != nil {
+		return fmt.Errorf("failed to encode meter read confirmation: %w", err)
+	}
+	rawOIDs, err := json.Marshal(read.RawOIDs)
+	if err != nil {
+		return fmt.Errorf("failed to encode meter read OIDs: %w", err)
+	}
+
+	result, err := s.db.ExecContext(ctx, `
+		INSERT INTO meter_reads (
+			id, serial, agent_id, ip, manufacturer, model, period, trigger, read_at,
+			page_count, mono_pages, color_pages, scan_count, confirmation, raw_oids,
+			signature_alg, key_id, signature
+		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT(id) DO NOTHING
+	`, read.ID, read.Serial, read.AgentID, read.IP, read.Manufacturer, read.Model, read.Period, read.Trigger,
+		read.ReadAt.UTC(), read.PageCount, read.MonoPages, read.ColorPages, read.ScanCount,
+		string(confirmation), string(rawOIDs), read.SignatureAlg, read.KeyID, read.Signature)
+	if err != nil {
+		return fmt.Errorf("failed to add meter read: %w", err)
+	}
+	if n, err := result.RowsAffected(); err == nil && n == 0 {
+		return ErrMeterReadExists
+	}
+	return nil
+}
+
+// ListMeterReads returns reads for a device (all devices if serial is empty), newest first.
+func (s *SQLiteStore) ListMeterReads(ctx context.Context, serial string, limit int) ([]*MeterRead, error) {
+	query := meterReadSelect
+	var args []interface{}
+	if serial != "" {
+		query += " WHERE serial = ?"
+		args = append(args, serial)
+	}
+	query += " ORDER BY read_at DESC"
+	if limit > 0 {
+		query += " LIMIT ?"
+		args = append(args, limit)
+	}
+	return s.queryMeterReads(ctx, query, args...)
+}
+
+// LatestMeterReadPeriod returns the period of the newest read with the given trigger ("" if none).
+func (s *SQLiteStore) LatestMeterReadPeriod(ctx context.Context, serial, trigger string) (string, error) {
+	var period string
+	err := s.db.QueryRowContext(ctx, `
+		SELECT period FROM meter_reads WHERE serial = ? AND trigger = ?
+		ORDER BY read_at DESC LIMIT 1
+	`, serial, trigger).Scan(&period)
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+	return period, err
+}
+
+// ListPendingMeterReads returns reads not yet uploaded to the server, oldest
+// first. Quarantined reads are left out.
+func (s *SQLiteStore) ListPendingMeterReads(ctx context.Context, limit int) ([]*MeterRead, error) {
+	query := meterReadSelect + " WHERE uploaded_at IS NULL AND quarantined_at IS NULL ORDER BY read_at ASC"
+	var args []interface{}
+	if limit > 0 {
+		query += " LIMIT ?"
+		args = append(args, limit)
+	}
+	return s.queryMeterReads(ctx, query, args...)
+}
+
+// MarkMeterReadsUploaded records that the given reads reached the server.
+func (s *SQLiteStore) MarkMeterReadsUploaded(ctx context.Context, ids []string, at time.Time) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	placeholders := make([]string, len(ids))
+	args := []interface{}{at.UTC()}
+	for i, id := range ids {
+		placeholders[i] = "?"
+		args = append(args, id)
+	}
+	_, err := s.db.ExecContext(ctx,
+		`UPDATE meter_reads SET uploaded_at = ? WHERE uploaded_at IS NULL AND id IN (`+strings.Join(placeholders, ",")+`)`,
+		args...)
+	if err != nil {
+		return fmt.Errorf("failed to mark meter reads uploaded: %w", err)
+	}
+	return nil
+}
+
+// QuarantineMeterRead stops uploading a read the server refused (for example
+// a signature it could not verify), keeping it with the reason.
+func (s *SQLiteStore) QuarantineMeterRead(ctx context.Context, id, reason string, at time.Time) error {
+	_, err := s.db.ExecContext(ctx,
+		`UPDATE meter_reads SET quarantined_at = ?, quarantine_reason = ? WHERE id = ? AND uploaded_at IS NULL`,
+		at.UTC(), reason, id)
+	if err != nil {
+		return fmt.Errorf("failed to quarantine meter read: %w", err)
+	}
+	return nil
+}
+
+const meterReadSelect = `
+	SELECT id, serial, agent_id, ip, manufacturer, model, period, trigger, read_at,
+	       page_count, mono_pages, color_pages, scan_count, confirmation, raw_oids,
+	       signature_alg, key_id, signature, uploaded_at, quarantined_at, quarantine_reason
+	FROM meter_reads`
+
+func (s *SQLiteStore) queryMeterReads(ctx context.Context, query string, args ...interface{}) ([]*MeterRead, error) {
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query meter reads: %w", err)
+	}
+	defer rows.Close()
+
+	var reads []*MeterRead
+	for rows.Next() {
+		var r MeterRead
+		var agentID, ip, manufacturer, model, confirmation, rawOIDs, sigAlg, keyID, sig, quarantineReason sql.NullString
+		var uploadedAt, quarantinedAt sql.NullTime
+		if err := rows.Scan(&r.ID, &r.Serial, &agentID, &ip, &manufacturer, &model, &r.Period, &r.Trigger, &r.ReadAt,
+			&r.PageCount, &r.MonoPages, &r.ColorPages, &r.ScanCount, &confirmation, &rawOIDs,
+			&sigAlg, &keyID, &sig, &uploadedAt, &quarantinedAt, &quarantineReason); err != nil {
+			return nil, fmt.Errorf("failed to scan meter read: %w", err)
+		}
+		r.AgentID = agentID.String
+		r.IP = ip.String
+		r.Manufacturer = manufacturer.String
+		r.Model = model.String
+		r.SignatureAlg = sigAlg.String
+		r.KeyID = keyID.String
+		r.Signature = sig.String
+		if confirmation.Valid && confirmation.String != "" {
+			_ = json.Unmarshal([]byte(confirmation.String), &r.Confirmation)
+		}
+		if rawOIDs.Valid && rawOIDs.String != "" {
+			_ = json.Unmarshal([]byte(rawOIDs.String), &r.RawOIDs)
+		}
+		if uploadedAt.Valid {
+			r.UploadedAt = uploadedAt.Time
+		}
+		if quarantinedAt.Valid {
+			r.QuarantinedAt = quarantinedAt.Time
+			r.QuarantineReason = quarantineReason.String
+		}
+		reads = append(reads, &r)
+	}
+	return reads, rows.Err()
+}
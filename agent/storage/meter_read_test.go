@@ -0,0 +1,82 @@
+package storage
+
+import (
+	"context"
+	"crypto/ed25519"
+	"errors"
+	"testing"
+	"time"
+
+	"printmaster/common/meterread"
+)
+
+func TestSQLiteStore_MeterReads(t *testing.T) {
+	store, err := NewSQLiteStore(":memory:")
+	if err != nil {
+		t.Fatalf("failed to create store: %v", err)
+	}
+	defer store.Close()
+
+	ctx := context.Background()
+	pub, key, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	read := &MeterRead{
+		ID:        "mr-1",
+		Serial:    "TEST123",
+		AgentID:   "agent-1",
+		IP:        "10.0.0.1",
+		Period:    "2026-09",
+		Trigger:   meterread.TriggerScheduled,
+		ReadAt:    time.Date(2026, 9, 1, 2, 0, 0, 123456789, time.UTC),
+		PageCount: 5000,
+		MonoPages: 4000,
+		Confirmation: meterread.Confirmation{
+			ReportedSerial: "TEST123",
+			SerialMatch:    true,
+			SysUpTime:      123456,
+		},
+		RawOIDs: []meterread.OIDValue{{OID: "1.3.6.1.2.1.43.10.2.1.4.1.1", Type: "Counter32", Value: "5000"}},
+	}
+	read.Sign(key)
+
+	if err := store.AddMeterRead(ctx, read); err != nil {
+		t.Fatalf("AddMeterRead: %v", err)
+	}
+	if err := store.AddMeterRead(ctx, read); !errors.Is(err, ErrMeterReadExists) {
+		t.Fatalf("duplicate AddMeterRead = %v, want ErrMeterReadExists", err)
+	}
+
+	reads, err := store.ListMeterReads(ctx, "TEST123", 0)
+	if err != nil || len(reads) != 1 {
+		t.Fatalf("ListMeterReads = %d, %v", len(reads), err)
+	}
+	if err := reads[0].Verify(pub); err != nil {
+		t.Errorf("stored read no longer verifies: %v", err)
+	}
+
+	period, err := store.LatestMeterReadPeriod(ctx, "TEST123", meterread.TriggerScheduled)
+	if err != nil || period != "2026-09" {
+		t.Errorf("LatestMeterReadPeriod = %q, %v", period, err)
+	}
+
+	pending, err := store.ListPendingMeterReads(ctx, 10)
+	if err != nil || len(pending) != 1 {
+		t.Fatalf("ListPendingMeterReads = %d, %v", len(pending), err)
+	}
+	if err := store.MarkMeterReadsUploaded(ctx, []string{"mr-1"}, time.Now()); err != nil {
+		t.Fatalf("MarkMeterReadsUploaded: %v", err)
+	}
+	if pending, _ := store.ListPendingMeterReads(ctx, 10); len(pending) != 0 {
+		t.Errorf("expected no pending reads after upload, got %d", len(pending))
+	}
+
+	// Signed columns cannot be changed or deleted
+	if _, err := store.db.ExecContext(ctx, `UPDATE meter_reads SET page_count = 1 WHERE id = 'mr-1'`); err == nil {
+		t.Error("expected update of signed column to fail")
+	}
+	if _, err := store.db.ExecContext(ctx, `DELETE FROM meter_reads WHERE id = 'mr-1'`); err == nil {
+		t.Error("expected delete to fail")
+	}
+}
@@ -11,7 +11,7 @@ import (
 	_ "modernc.org/sqlite" // Ensure driver is imported for BackupAndReset
 )
 
-const targetSchemaVersion = 9
+const targetSchemaVersion = 13
 
 // expectedSchema defines the target schema structure for auto-migration
 var expectedSchema = map[string][]string{
@@ -64,6 +64,8 @@ var expectedSchema = map[string][]string{
 		"scan_to_host_flatbed INTEGER DEFAULT 0",
 		"scan_to_host_adf INTEGER DEFAULT 0",
 		"duplex_sheets INTEGER DEFAULT 0",
+		"simplex_sheets INTEGER DEFAULT 0",
+		"nup_sheets INTEGER DEFAULT 0",
 		"jam_events INTEGER DEFAULT 0",
 		"scanner_jam_events INTEGER DEFAULT 0",
 	},
@@ -90,6 +92,8 @@ var expectedSchema = map[string][]string{
 		"scan_to_host_flatbed INTEGER DEFAULT 0",
 		"scan_to_host_adf INTEGER DEFAULT 0",
 		"duplex_sheets INTEGER DEFAULT 0",
+		"simplex_sheets INTEGER DEFAULT 0",
+		"nup_sheets INTEGER DEFAULT 0",
 		"jam_events INTEGER DEFAULT 0",
 		"scanner_jam_events INTEGER DEFAULT 0",
 	},
@@ -383,8 +387,8 @@ func (s *SQLiteStore) recreateTable(tableName string, expectedCols, actualCols [
 
 // BackupAndReset backs up the current database and starts fresh (exported for API access)
 func (s *SQLiteStore) BackupAndReset() error {
-	// Close current connection
-	if err := s.db.Close(); err != nil {
+	// Close current connection (an encrypted store writes its final image first)
+	if err := s.Close(); err != nil {
 		storageLogger.Warn("Error closing database for backup", "error", err)
 	}
 
@@ -400,26 +404,32 @@ func (s *SQLiteStore) BackupAndReset() error {
 		return s.initSchema()
 	}
 
-	backupPath := fmt.Sprintf("%s.backup_%s", dbPath, time.Now().Format("20060102_150405"))
+	// Encrypted stores back up the sealed image, which stays encrypted
+	filePath := dbPath
+	if s.enc != nil {
+		filePath = s.enc.path
+	}
+	backupPath := fmt.Sprintf("%s.backup_%s", filePath, time.Now().Format("20060102_150405"))
 
 	// Copy database file
-	if err := copyFile(dbPath, backupPath); err != nil {
+	if err := copyFile(filePath, backupPath); err != nil {
 		storageLogger.Error("Failed to backup database", "error", err)
 	} else {
 		storageLogger.Info("Database backed up", "path", backupPath)
 	}
 
 	// Remove old database
-	if err := os.Remove(dbPath); err != nil {
+	if err := os.Remove(filePath); err != nil && !(s.enc != nil && os.IsNotExist(err)) {
 		return fmt.Errorf("failed to remove old database: %w", err)
 	}
 
 	// Open fresh database
-	db, err := sql.Open("sqlite", dbPath)
+	db, enc, err := openSQLite(dbPath)
 	if err != nil {
 		return fmt.Errorf("failed to open fresh database: %w", err)
 	}
 	s.db = db
+	s.enc = enc
 
 	// Initialize fresh schema
 	return s.initSchema()
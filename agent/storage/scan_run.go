@@ -0,0 +1,228 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// Scan run statuses.
+const (
+	ScanRunCompleted = "completed"
+	ScanRunFailed    = "failed"
+)
+
+// ScanRun records one discovery pass and the devices it found, so two passes
+// can be compared later.
+type ScanRun struct {
+	ID          int64           `json:"id"`
+	Source      string          `json:"source"` // manual, auto or cli
+	Mode        string          `json:"mode"`
+	Ranges      []string        `json:"ranges"`
+	StartedAt   time.Time       `json:"started_at"`
+	FinishedAt  time.Time       `json:"finished_at"`
+	Status      string          `json:"status"`
+	Error       string          `json:"error,omitempty"`
+	DeviceCount int             `json:"device_count"`
+	Devices     []ScanRunDevice `json:"devices,omitempty"`
+}
+
+// ScanRunDevice is the identity of a device as seen by a scan run. ScanID
+// points at the device's scan_history snapshot taken during the run, if any.
+type ScanRunDevice struct {
+	Serial       string `json:"serial,omitempty"`
+	IP           string `json:"ip"`
+	Manufacturer string `json:"manufacturer,omitempty"`
+	Model        string `json:"model,omitempty"`
+	Hostname     string `json:"hostname,omitempty"`
+	Firmware     string `json:"firmware,omitempty"`
+	MACAddress   string `json:"mac_address,omitempty"`
+	ScanID       int64  `json:"scan_id,omitempty"`
+}
+
+// Key identifies the device across scan runs: its serial, or its IP when the
+// serial is unknown.
+func (d ScanRunDevice) Key() string {
+	if d.Serial != "" {
+		return d.Serial
+	}
+	return "ip:" + d.IP
+}
+
+// ScanRunStore defines operations for discovery run history.
+type ScanRunStore interface {
+	// AddScanRun stores a run and its devices, setting run.ID
+	AddScanRun(ctx context.Context, run *ScanRun) error
+
+	// GetScanRun returns a run with its devices (nil if not found)
+	GetScanRun(ctx context.Context, id int64) (*ScanRun, error)
+
+	// ListScanRuns returns runs without devices, newest first
+	ListScanRuns(ctx context.Context, limit int) ([]*ScanRun, error)
+
+	// PreviousScanRun returns the newest completed run before id (nil if none)
+	PreviousScanRun(ctx context.Context, id int64) (*ScanRun, error)
+
+	// DeleteScanRunsBefore removes runs started before the cutoff (unix seconds)
+	DeleteScanRunsBefore(ctx context.Context, olderThan int64) (int, error)
+}
+
+const scanRunSelect = `SELECT id, source, mode, ranges, started_at, finished_at, status, error, device_count FROM scan_runs`
+
+// AddScanRun stores a run and its devices, setting run.ID.
+func (s *SQLiteStore) AddScanRun(ctx context.Context, run *ScanRun) error {
+	if run == nil {
+		return fmt.Errorf("scan run is required")
+	}
+	ranges, err := json.Marshal(run.Ranges)
+	if err != nil {
+		return fmt.Errorf("failed to encode scan run ranges: %w", err)
+	}
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	run.DeviceCount = len(run.Devices)
+	result, err := tx.ExecContext(ctx, `
+		INSERT INTO scan_runs (source, mode, ranges, started_at, finished_at, status, error, device_count)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+	`, run.Source, run.Mode, string(ranges), run.StartedAt.UTC(), run.FinishedAt.UTC(), run.Status, run.Error, run.DeviceCount)
+	if err != nil {
+		return fmt.Errorf("failed to add scan run: %w", err)
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return fmt.Errorf("failed to get scan run id: %w", err)
+	}
+
+	stmt, err := tx.PrepareContext(ctx, `
+		INSERT INTO scan_run_devices (run_id, device_key, serial, ip, manufacturer, model, hostname, firmware, mac_address, scan_id)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT(run_id, device_key) DO NOTHING
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to prepare scan run devices: %w", err)
+	}
+	defer stmt.Close()
+	for _, d := range run.Devices {
+		var scanID interface{}
+		if d.ScanID > 0 {
+			scanID = d.ScanID
+		}
+		if _, err := stmt.ExecContext(ctx, id, d.Key(), d.Serial, d.IP, d.Manufacturer, d.Model,
+			d.Hostname, d.Firmware, d.MACAddress, scanID); err != nil {
+			return fmt.Errorf("failed to add scan run device: %w", err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit scan run: %w", err)
+	}
+	run.ID = id
+	return nil
+}
+
+// GetScanRun returns a run with its devices (nil if not found).
+func (s *SQLiteStore) GetScanRun(ctx context.Context, id int64) (*ScanRun, error) {
+	runs, err := s.queryScanRuns(ctx, scanRunSelect+" WHERE id = ?", id)
+	if err != nil || len(runs) == 0 {
+		return nil, err
+	}
+	run := runs[0]
+
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT serial, ip, manufacturer, model, hostname, firmware, mac_address, scan_id
+		FROM scan_run_devices WHERE run_id = ? ORDER BY device_key
+	`, id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query scan run devices: %w", err)
+	}
+	defer rows.Close()
+	run.Devices = []ScanRunDevice{}
+	for rows.Next() {
+		var d ScanRunDevice
+		var serial, manufacturer, model, hostname, firmware, mac sql.NullString
+		var scanID sql.NullInt64
+		if err := rows.Scan(&serial, &d.IP, &manufacturer, &model, &hostname, &firmware, &mac, &scanID); err != nil {
+			return nil, fmt.Errorf("failed to scan scan run device: %w", err)
+		}
+		d.Serial = serial.String
+		d.Manufacturer = manufacturer.String
+		d.Model = model.String
+		d.Hostname = hostname.String
+		d.Firmware = firmware.String
+		d.MACAddress = mac.String
+		d.ScanID = scanID.Int64
+		run.Devices = append(run.Devices, d)
+	}
+	return run, rows.Err()
+}
+
+// ListScanRuns returns runs without devices, newest first.
+func (s *SQLiteStore) ListScanRuns(ctx context.Context, limit int) ([]*ScanRun, error) {
+	query := scanRunSelect + " ORDER BY id DESC"
+	var args []interface{}
+	if limit > 0 {
+		query += " LIMIT ?"
+		args = append(args, limit)
+	}
+	return s.queryScanRuns(ctx, query, args...)
+}
+
+// PreviousScanRun returns the newest completed run before id (nil if none).
+func (s *SQLiteStore) PreviousScanRun(ctx context.Context, id int64) (*ScanRun, error) {
+	var prevID int64
+	err := s.db.QueryRowContext(ctx,
+		"SELECT id FROM scan_runs WHERE id < ? AND status = ? ORDER BY id DESC LIMIT 1",
+		id, ScanRunCompleted).Scan(&prevID)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to find previous scan run: %w", err)
+	}
+	return s.GetScanRun(ctx, prevID)
+}
+
+// DeleteScanRunsBefore removes runs started before the cutoff (unix seconds).
+func (s *SQLiteStore) DeleteScanRunsBefore(ctx context.Context, olderThan int64) (int, error) {
+	cutoff := time.Unix(olderThan, 0).UTC()
+	if _, err := s.db.ExecContext(ctx,
+		"DELETE FROM scan_run_devices WHERE run_id IN (SELECT id FROM scan_runs WHERE started_at < ?)", cutoff); err != nil {
+		return 0, fmt.Errorf("failed to delete old scan run devices: %w", err)
+	}
+	result, err := s.db.ExecContext(ctx, "DELETE FROM scan_runs WHERE started_at < ?", cutoff)
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete old scan runs: %w", err)
+	}
+	rows, _ := result.RowsAffected()
+	return int(rows), nil
+}
+
+func (s *SQLiteStore) queryScanRuns(ctx context.Context, query string, args ...interface{}) ([]*ScanRun, error) {
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query scan runs: %w", err)
+	}
+	defer rows.Close()
+
+	var runs []*ScanRun
+	for rows.Next() {
+		var run ScanRun
+		var mode, ranges, errMsg sql.NullString
+		if err := rows.Scan(&run.ID, &run.Source, &mode, &ranges, &run.StartedAt, &run.FinishedAt,
+			&run.Status, &errMsg, &run.DeviceCount); err != nil {
+			return nil, fmt.Errorf("failed to scan scan run: %w", err)
+		}
+		run.Mode = mode.String
+		run.Error = errMsg.String
+		if ranges.Valid && ranges.String != "" {
+			_ = json.Unmarshal([]byte(ranges.String), &run.Ranges)
+		}
+		runs = append(runs, &run)
+	}
+	return runs, rows.Err()
+}
@@ -0,0 +1,74 @@
+package storage
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestSQLiteStore_ScanRuns(t *testing.T) {
+	store, err := NewSQLiteStore(":memory:")
+	if err != nil {
+		t.Fatalf("failed to create store: %v", err)
+	}
+	defer store.Close()
+
+	ctx := context.Background()
+	old := time.Now().Add(-60 * 24 * time.Hour)
+	first := &ScanRun{
+		Source:     "auto",
+		Mode:       "full",
+		Ranges:     []string{"10.0.0.0/24"},
+		StartedAt:  old,
+		FinishedAt: old.Add(time.Minute),
+		Status:     ScanRunCompleted,
+		Devices: []ScanRunDevice{
+			{Serial: "A1", IP: "10.0.0.5", Model: "M1"},
+			{IP: "10.0.0.9"},
+		},
+	}
+	if err := store.AddScanRun(ctx, first); err != nil {
+		t.Fatalf("AddScanRun: %v", err)
+	}
+	now := time.Now()
+	failed := &ScanRun{Source: "manual", StartedAt: now, FinishedAt: now, Status: ScanRunFailed, Error: "boom"}
+	if err := store.AddScanRun(ctx, failed); err != nil {
+		t.Fatalf("AddScanRun failed run: %v", err)
+	}
+	second := &ScanRun{Source: "manual", StartedAt: now, FinishedAt: now, Status: ScanRunCompleted,
+		Devices: []ScanRunDevice{{Serial: "A1", IP: "10.0.0.6", ScanID: 7}}}
+	if err := store.AddScanRun(ctx, second); err != nil {
+		t.Fatalf("AddScanRun second: %v", err)
+	}
+
+	got, err := store.GetScanRun(ctx, first.ID)
+	if err != nil || got == nil {
+		t.Fatalf("GetScanRun = %v, %v", got, err)
+	}
+	if got.DeviceCount != 2 || len(got.Devices) != 2 || len(got.Ranges) != 1 {
+		t.Fatalf("unexpected run: %+v", got)
+	}
+	if missing, err := store.GetScanRun(ctx, 999); err != nil || missing != nil {
+		t.Errorf("GetScanRun(missing) = %v, %v", missing, err)
+	}
+
+	prev, err := store.PreviousScanRun(ctx, second.ID)
+	if err != nil || prev == nil || prev.ID != first.ID {
+		t.Fatalf("PreviousScanRun = %+v, %v (want run %d, skipping failed run)", prev, err, first.ID)
+	}
+
+	runs, err := store.ListScanRuns(ctx, 0)
+	if err != nil || len(runs) != 3 || runs[0].ID != second.ID {
+		t.Fatalf("ListScanRuns = %d, %v", len(runs), err)
+	}
+
+	deleted, err := store.DeleteScanRunsBefore(ctx, time.Now().Add(-30*24*time.Hour).Unix())
+	if err != nil || deleted != 1 {
+		t.Fatalf("DeleteScanRunsBefore = %d, %v", deleted, err)
+	}
+	var orphaned int
+	store.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM scan_run_devices WHERE run_id = ?", first.ID).Scan(&orphaned)
+	if orphaned != 0 {
+		t.Errorf("expected devices of deleted run to be removed, got %d", orphaned)
+	}
+}
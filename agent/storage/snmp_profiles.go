@@ -0,0 +1,155 @@
+package storage
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// SNMPProfile is a named set of SNMP credentials tried against devices in
+// the listed subnets. Lower Priority values are tried first. Community and
+// passwords are stored as given; callers encrypt them before saving.
+type SNMPProfile struct {
+	ID       int64    `json:"id"`
+	Name     string   `json:"name"`
+	Priority int      `json:"priority"`
+	Subnets  []string `json:"subnets,omitempty"` // CIDRs; empty matches every device
+	Version  string   `json:"version"`           // "1", "2c" or "3"
+
+	Community string `json:"community,omitempty"`
+
+	SecurityLevel string `json:"security_level,omitempty"` // noAuthNoPriv, authNoPriv or authPriv
+	Username      string `json:"username,omitempty"`
+	AuthProtocol  string `json:"auth_protocol,omitempty"`
+	AuthPassword  string `json:"auth_password,omitempty"`
+	PrivProtocol  string `json:"priv_protocol,omitempty"`
+	PrivPassword  string `json:"priv_password,omitempty"`
+	ContextName   string `json:"context_name,omitempty"`
+
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+const snmpProfilesSchema = `
+	CREATE TABLE IF NOT EXISTS snmp_profiles (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		name TEXT NOT NULL UNIQUE,
+		priority INTEGER NOT NULL DEFAULT 100,
+		subnets TEXT,
+		version TEXT NOT NULL,
+		community TEXT,
+		security_level TEXT,
+		username TEXT,
+		auth_protocol TEXT,
+		auth_password TEXT,
+		priv_protocol TEXT,
+		priv_password TEXT,
+		context_name TEXT,
+		updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+	);
+`
+
+// ListSNMPProfiles returns all credential profiles in the order they are tried
+func (s *SQLiteAgentConfig) ListSNMPProfiles() ([]SNMPProfile, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	rows, err := s.db.Query(`
+		SELECT id, name, priority, subnets, version, community, security_level, username,
+		       auth_protocol, auth_password, priv_protocol, priv_password, context_name, updated_at
+		FROM snmp_profiles ORDER BY priority, id
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list SNMP profiles: %w", err)
+	}
+	defer rows.Close()
+
+	profiles := []SNMPProfile{}
+	for rows.Next() {
+		var p SNMPProfile
+		var subnets, community, secLevel, username, authProto, authPass, privProto, privPass, contextName sql.NullString
+		if err := rows.Scan(&p.ID, &p.Name, &p.Priority, &subnets, &p.Version, &community, &secLevel, &username,
+			&authProto, &authPass, &privProto, &privPass, &contextName, &p.UpdatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan SNMP profile: %w", err)
+		}
+		if subnets.String != "" {
+			if err := json.Unmarshal([]byte(subnets.String), &p.Subnets); err != nil {
+				return nil, fmt.Errorf("failed to decode subnets of SNMP profile %q: %w", p.Name, err)
+			}
+		}
+		p.Community = community.String
+		p.SecurityLevel = secLevel.String
+		p.Username = username.String
+		p.AuthProtocol = authProto.String
+		p.AuthPassword = authPass.String
+		p.PrivProtocol = privProto.String
+		p.PrivPassword = privPass.String
+		p.ContextName = contextName.String
+		profiles = append(profiles, p)
+	}
+	return profiles, rows.Err()
+}
+
+// SaveSNMPProfile inserts p when p.ID is zero (setting p.ID) or replaces the
+// profile with that ID
+func (s *SQLiteAgentConfig) SaveSNMPProfile(p *SNMPProfile) error {
+	if p == nil || p.Name == "" {
+		return fmt.Errorf("SNMP profile name required")
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	subnets := ""
+	if len(p.Subnets) > 0 {
+		raw, err := json.Marshal(p.Subnets)
+		if err != nil {
+			return fmt.Errorf("failed to encode subnets: %w", err)
+		}
+		subnets = string(raw)
+	}
+	p.UpdatedAt = time.Now().UTC()
+	args := []interface{}{p.Name, p.Priority, subnets, p.Version, p.Community, p.SecurityLevel, p.Username,
+		p.AuthProtocol, p.AuthPassword, p.PrivProtocol, p.PrivPassword, p.ContextName, p.UpdatedAt}
+
+	if p.ID == 0 {
+		res, err := s.db.Exec(`
+			INSERT INTO snmp_profiles (name, priority, subnets, version, community, security_level, username,
+				auth_protocol, auth_password, priv_protocol, priv_password, context_name, updated_at)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		`, args...)
+		if err != nil {
+			return fmt.Errorf("failed to save SNMP profile: %w", err)
+		}
+		p.ID, err = res.LastInsertId()
+		return err
+	}
+
+	res, err := s.db.Exec(`
+		UPDATE snmp_profiles SET name = ?, priority = ?, subnets = ?, version = ?, community = ?, security_level = ?,
+			username = ?, auth_protocol = ?, auth_password = ?, priv_protocol = ?, priv_password = ?, context_name = ?,
+			updated_at = ?
+		WHERE id = ?
+	`, append(args, p.ID)...)
+	if err != nil {
+		return fmt.Errorf("failed to save SNMP profile: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// DeleteSNMPProfile removes a credential profile
+func (s *SQLiteAgentConfig) DeleteSNMPProfile(id int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	res, err := s.db.Exec(`DELETE FROM snmp_profiles WHERE id = ?`, id)
+	if err != nil {
+		return fmt.Errorf("failed to delete SNMP profile: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
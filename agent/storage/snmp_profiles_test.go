@@ -0,0 +1,54 @@
+package storage
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+)
+
+func TestSNMPProfilesCRUD(t *testing.T) {
+	store, err := NewAgentConfigStore(filepath.Join(t.TempDir(), "agent.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+
+	v3 := &SNMPProfile{Name: "office", Priority: 20, Subnets: []string{"10.8.0.0/24"}, Version: "3",
+		SecurityLevel: "authPriv", Username: "printers", AuthProtocol: "SHA256", AuthPassword: "enc-auth",
+		PrivProtocol: "AES", PrivPassword: "enc-priv"}
+	v2 := &SNMPProfile{Name: "legacy", Priority: 5, Version: "2c", Community: "enc-community"}
+	for _, p := range []*SNMPProfile{v3, v2} {
+		if err := store.SaveSNMPProfile(p); err != nil || p.ID == 0 {
+			t.Fatalf("save %s: id=%d err=%v", p.Name, p.ID, err)
+		}
+	}
+	if err := store.SaveSNMPProfile(&SNMPProfile{Name: "office", Version: "2c"}); err == nil {
+		t.Fatal("expected an error for a duplicate name")
+	}
+
+	list, err := store.ListSNMPProfiles()
+	if err != nil || len(list) != 2 {
+		t.Fatalf("list: %v %v", list, err)
+	}
+	if list[0].Name != "legacy" || list[1].Subnets[0] != "10.8.0.0/24" || list[1].PrivPassword != "enc-priv" {
+		t.Fatalf("profiles not ordered by priority or fields lost: %+v", list)
+	}
+
+	v3.Priority = 1
+	if err := store.SaveSNMPProfile(v3); err != nil {
+		t.Fatal(err)
+	}
+	if list, _ := store.ListSNMPProfiles(); list[0].ID != v3.ID {
+		t.Fatalf("update not applied: %+v", list)
+	}
+
+	if err := store.DeleteSNMPProfile(v2.ID); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.DeleteSNMPProfile(v2.ID); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected ErrNotFound, got %v", err)
+	}
+	if err := store.SaveSNMPProfile(&SNMPProfile{ID: 999, Name: "ghost", Version: "2c"}); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected ErrNotFound for a missing profile, got %v", err)
+	}
+}
@@ -32,7 +32,8 @@ func SetLogger(logger Logger) {
 // SQLiteStore implements DeviceStore using SQLite
 type SQLiteStore struct {
 	db     *sql.DB
-	dbPath string // Store path for backup operations
+	dbPath string       // Store path for backup operations
+	enc    *encryptedDB // Non-nil when encrypted at rest
 }
 
 type execer interface {
@@ -53,10 +54,15 @@ func NewSQLiteStoreWithConfig(dbPath string, configStore AgentConfigStore) (*SQL
 		dbPath = ":memory:"
 	}
 
-	db, err := sql.Open("sqlite", dbPath)
+	db, enc, err := openSQLite(dbPath)
 	if err != nil {
 		return nil, fmt.Errorf("failed to open database: %w", err)
 	}
+	store := &SQLiteStore{
+		db:     db,
+		dbPath: dbPath,
+		enc:    enc,
+	}
 
 	// Connection pool settings for SQLite:
 	// - MaxOpenConns: Allow multiple connections for reads (WAL mode supports this)
@@ -77,19 +83,14 @@ func NewSQLiteStoreWithConfig(dbPath string, configStore AgentConfigStore) (*SQL
 	}
 	for _, pragma := range pragmas {
 		if _, err := db.Exec(pragma); err != nil {
-			db.Close()
+			store.Close()
 			return nil, fmt.Errorf("failed to set pragma: %w", err)
 		}
 	}
 
-	store := &SQLiteStore{
-		db:     db,
-		dbPath: dbPath,
-	}
-
 	// Initialize schema
 	if err := store.initSchema(); err != nil {
-		db.Close()
+		store.Close()
 
 		// If schema initialization fails and we have a real database file (not in-memory),
 		// rotate the corrupted database and try again with a fresh one
@@ -99,7 +100,11 @@ func NewSQLiteStoreWithConfig(dbPath string, configStore AgentConfigStore) (*SQL
 					"error", err, "path", dbPath)
 			}
 
-			backupPath, rotateErr := RotateDatabase(dbPath, configStore)
+			rotatePath := dbPath
+			if enc != nil {
+				rotatePath = enc.path
+			}
+			backupPath, rotateErr := RotateDatabase(rotatePath, configStore)
 			if rotateErr != nil {
 				return nil, fmt.Errorf("failed to initialize schema and unable to rotate database: %w (rotation error: %v)", err, rotateErr)
 			}
@@ -120,7 +125,7 @@ func NewSQLiteStoreWithConfig(dbPath string, configStore AgentConfigStore) (*SQL
 
 	// Run auto-migration
 	if err := store.autoMigrate(); err != nil {
-		db.Close()
+		store.Close()
 		return nil, fmt.Errorf("failed to migrate schema: %w", err)
 	}
 
@@ -204,6 +209,11 @@ func (s *SQLiteStore) initSchema() error {
 		mono_pages INTEGER DEFAULT 0,
 		scan_count INTEGER DEFAULT 0,
 		toner_levels TEXT,
+		duplex_sheets INTEGER DEFAULT 0,
+		simplex_sheets INTEGER DEFAULT 0,
+		nup_sheets INTEGER DEFAULT 0,
+		counter_source TEXT,
+		counter_confidence TEXT,
 		FOREIGN KEY (serial) REFERENCES devices(serial) ON DELETE CASCADE
 	);
 
@@ -358,6 +368,134 @@ func (s *SQLiteStore) initSchema() error {
 	CREATE INDEX IF NOT EXISTS idx_page_count_audit_serial ON page_count_audit(serial);
 	CREATE INDEX IF NOT EXISTS idx_page_count_audit_timestamp ON page_count_audit(timestamp);
 	CREATE INDEX IF NOT EXISTS idx_page_count_audit_serial_timestamp ON page_count_audit(serial, timestamp);
+
+	-- Certified meter reads for billing (signed, immutable once written)
+	CREATE TABLE IF NOT EXISTS meter_reads (
+		id TEXT PRIMARY KEY,
+		serial TEXT NOT NULL,
+		agent_id TEXT,
+		ip TEXT,
+		manufacturer TEXT,
+		model TEXT,
+		period TEXT NOT NULL,
+		trigger TEXT NOT NULL,
+		read_at DATETIME NOT NULL,
+		page_count INTEGER DEFAULT 0,
+		mono_pages INTEGER DEFAULT 0,
+		color_pages INTEGER DEFAULT 0,
+		scan_count INTEGER DEFAULT 0,
+		confirmation TEXT,
+		raw_oids TEXT,
+		signature_alg TEXT,
+		key_id TEXT,
+		signature TEXT,
+		uploaded_at DATETIME,
+		quarantined_at DATETIME,
+		quarantine_reason TEXT
+	);
+
+	CREATE INDEX IF NOT EXISTS idx_meter_reads_serial_read_at ON meter_reads(serial, read_at);
+	CREATE INDEX IF NOT EXISTS idx_meter_reads_uploaded ON meter_reads(uploaded_at);
+
+	CREATE TRIGGER IF NOT EXISTS meter_reads_immutable
+	BEFORE UPDATE OF id, serial, agent_id, ip, manufacturer, model, period, trigger, read_at,
+		page_count, mono_pages, color_pages, scan_count, confirmation, raw_oids, signature_alg, key_id, signature
+	ON meter_reads
+	BEGIN
+		SELECT RAISE(ABORT, 'meter reads are immutable');
+	END;
+
+	CREATE TRIGGER IF NOT EXISTS meter_reads_no_delete
+	BEFORE DELETE ON meter_reads
+	BEGIN
+		SELECT RAISE(ABORT, 'meter reads are immutable');
+	END;
+
+	-- Discovery runs and the devices each one found (for scan diffs)
+	CREATE TABLE IF NOT EXISTS scan_runs (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		source TEXT NOT NULL,
+		mode TEXT,
+		ranges TEXT,
+		started_at DATETIME NOT NULL,
+		finished_at DATETIME NOT NULL,
+		status TEXT NOT NULL,
+		error TEXT,
+		device_count INTEGER DEFAULT 0
+	);
+
+	CREATE INDEX IF NOT EXISTS idx_scan_runs_started_at ON scan_runs(started_at);
+
+	CREATE TABLE IF NOT EXISTS scan_run_devices (
+		run_id INTEGER NOT NULL,
+		device_key TEXT NOT NULL,
+		serial TEXT,
+		ip TEXT NOT NULL,
+		manufacturer TEXT,
+		model TEXT,
+		hostname TEXT,
+		firmware TEXT,
+		mac_address TEXT,
+		scan_id INTEGER,
+		PRIMARY KEY (run_id, device_key),
+		FOREIGN KEY (run_id) REFERENCES scan_runs(id) ON DELETE CASCADE
+	);
+
+	-- High-frequency polling sessions; times are RFC3339 text like metrics_raw
+	CREATE TABLE IF NOT EXISTS device_watches (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		serial TEXT NOT NULL,
+		interval_seconds INTEGER NOT NULL,
+		started_at TEXT NOT NULL,
+		expires_at TEXT NOT NULL,
+		ended_at TEXT,
+		end_reason TEXT,
+		samples INTEGER DEFAULT 0,
+		failures INTEGER DEFAULT 0,
+		last_error TEXT
+	);
+
+	CREATE INDEX IF NOT EXISTS idx_device_watches_serial ON device_watches(serial);
+
+	-- Last known configuration of each device and the changes seen since
+	CREATE TABLE IF NOT EXISTS device_config_baselines (
+		serial TEXT PRIMARY KEY,
+		config TEXT NOT NULL,
+		captured_at DATETIME NOT NULL
+	);
+
+	CREATE TABLE IF NOT EXISTS config_drift_events (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		serial TEXT NOT NULL,
+		ip TEXT,
+		field TEXT NOT NULL,
+		before_value TEXT,
+		after_value TEXT,
+		detected_at DATETIME NOT NULL
+	);
+
+	CREATE INDEX IF NOT EXISTS idx_config_drift_events_serial ON config_drift_events(serial);
+	CREATE INDEX IF NOT EXISTS idx_config_drift_events_detected_at ON config_drift_events(detected_at);
+
+	-- Alerts raised by the local alert engine
+	CREATE TABLE IF NOT EXISTS alerts (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		alert_key TEXT NOT NULL,
+		type TEXT NOT NULL,
+		severity TEXT NOT NULL,
+		serial TEXT NOT NULL,
+		ip TEXT,
+		supply TEXT,
+		title TEXT NOT NULL,
+		message TEXT,
+		value INTEGER DEFAULT 0,
+		threshold INTEGER DEFAULT 0,
+		raised_at DATETIME NOT NULL,
+		resolved_at DATETIME
+	);
+
+	CREATE INDEX IF NOT EXISTS idx_alerts_serial ON alerts(serial);
+	CREATE INDEX IF NOT EXISTS idx_alerts_open ON alerts(alert_key) WHERE resolved_at IS NULL;
 	`
 
 	_, err := s.db.Exec(schema)
@@ -865,6 +1003,79 @@ func (s *SQLiteStore) runMigrations() error {
 		}
 	}
 
+	// Migration 10 -> 11: Store duplex, simplex and N-up sheet counters with raw metrics
+	if currentVersion < 11 {
+		var tableExists int
+		err := s.db.QueryRow("SELECT COUNT(*) FROM sqlite_master WHERE type='table' AND name='metrics_raw'").Scan(&tableExists)
+		if err == nil && tableExists > 0 {
+			for _, col := range []string{"duplex_sheets", "simplex_sheets", "nup_sheets"} {
+				_, err = s.db.Exec(fmt.Sprintf(`ALTER TABLE metrics_raw ADD COLUMN %s INTEGER DEFAULT 0`, col))
+				if err != nil && !strings.Contains(err.Error(), "duplicate column") {
+					return fmt.Errorf("failed to add column %s: %w", col, err)
+				}
+			}
+		}
+
+		// Record migration
+		_, err = s.db.Exec(`INSERT OR REPLACE INTO schema_version (version, applied_at) VALUES (11, ?)`, time.Now())
+		if err != nil {
+			return fmt.Errorf("failed to record schema version: %w", err)
+		}
+
+		if storageLogger != nil {
+			storageLogger.Info("Applied schema migration 10->11: Duplex and N-up sheet counters")
+		}
+	}
+
+	// Migration 11 -> 12: Quarantine meter reads the server refused and record
+	// which signing key each read was signed with
+	if currentVersion < 12 {
+		var tableExists int
+		err := s.db.QueryRow("SELECT COUNT(*) FROM sqlite_master WHERE type='table' AND name='meter_reads'").Scan(&tableExists)
+		if err == nil && tableExists > 0 {
+			for _, col := range []string{"quarantined_at DATETIME", "quarantine_reason TEXT", "key_id TEXT"} {
+				_, err = s.db.Exec(fmt.Sprintf(`ALTER TABLE meter_reads ADD COLUMN %s`, col))
+				if err != nil && !strings.Contains(err.Error(), "duplicate column") {
+					return fmt.Errorf("failed to add column %s: %w", col, err)
+				}
+			}
+		}
+
+		// Record migration
+		_, err = s.db.Exec(`INSERT OR REPLACE INTO schema_version (version, applied_at) VALUES (12, ?)`, time.Now())
+		if err != nil {
+			return fmt.Errorf("failed to record schema version: %w", err)
+		}
+
+		if storageLogger != nil {
+			storageLogger.Info("Applied schema migration 11->12: Meter read quarantine and signing key IDs")
+		}
+	}
+
+	// Migration 12 -> 13: Record where raw metrics counters came from
+	if currentVersion < 13 {
+		var tableExists int
+		err := s.db.QueryRow("SELECT COUNT(*) FROM sqlite_master WHERE type='table' AND name='metrics_raw'").Scan(&tableExists)
+		if err == nil && tableExists > 0 {
+			for _, col := range []string{"counter_source", "counter_confidence"} {
+				_, err = s.db.Exec(fmt.Sprintf(`ALTER TABLE metrics_raw ADD COLUMN %s TEXT`, col))
+				if err != nil && !strings.Contains(err.Error(), "duplicate column") {
+					return fmt.Errorf("failed to add column %s: %w", col, err)
+				}
+			}
+		}
+
+		// Record migration
+		_, err = s.db.Exec(`INSERT OR REPLACE INTO schema_version (version, applied_at) VALUES (13, ?)`, time.Now())
+		if err != nil {
+			return fmt.Errorf("failed to record schema version: %w", err)
+		}
+
+		if storageLogger != nil {
+			storageLogger.Info("Applied schema migration 12->13: Metrics counter source")
+		}
+	}
+
 	// Schema repair: ensure critical columns exist regardless of recorded version
 	// This handles cases where migration tracking got out of sync with actual schema
 	if err := s.repairSchema(); err != nil {
@@ -1589,6 +1800,11 @@ func (s *SQLiteStore) Stats(ctx context.Context) (map[string]interface{}, error)
 
 // Close closes the database connection
 func (s *SQLiteStore) Close() error {
+	if s.enc != nil {
+		if err := s.enc.Close(); err != nil && storageLogger != nil {
+			storageLogger.Error("Failed to write encrypted database", "path", s.enc.path, "error", err)
+		}
+	}
 	if s.db != nil {
 		return s.db.Close()
 	}
@@ -1763,12 +1979,23 @@ type MetricsSnapshot struct {
 	ScanToHostFlatbed int `json:"scan_to_host_flatbed,omitempty"`
 	ScanToHostADF     int `json:"scan_to_host_adf,omitempty"`
 	DuplexSheets      int `json:"duplex_sheets,omitempty"`
+	SimplexSheets     int `json:"simplex_sheets,omitempty"`
+	NUpSheets         int `json:"nup_sheets,omitempty"`
 	JamEvents         int `json:"jam_events,omitempty"`
 	ScannerJamEvents  int `json:"scanner_jam_events,omitempty"`
 	// Tier indicates which storage tier this snapshot came from (raw/hourly/daily/monthly)
 	Tier string `json:"tier,omitempty"`
+	// CounterSource is empty for counters read over SNMP, or CounterSourceStatusPageOCR
+	// when they were recovered from the device status page. Only raw snapshots keep it.
+	CounterSource string `json:"counter_source,omitempty"`
+	// CounterConfidence is the OCR confidence (high/medium/low) when CounterSource is set
+	CounterConfidence string `json:"counter_confidence,omitempty"`
 }
 
+// CounterSourceStatusPageOCR marks page counters recovered by OCR of the
+// vendor status page instead of read over SNMP.
+const CounterSourceStatusPageOCR = "status_page_ocr"
+
 // SaveMetricsSnapshot stores a metrics snapshot for a device
 func (s *SQLiteStore) SaveMetricsSnapshot(ctx context.Context, snapshot *MetricsSnapshot) error {
 	return s.saveMetricsSnapshotWithExecer(ctx, s.db, snapshot)
@@ -1910,8 +2137,9 @@ func (s *SQLiteStore) saveMetricsSnapshotWithExecer(ctx context.Context, ex exec
 
 	query := `
 		INSERT INTO metrics_raw (
-			serial, timestamp, page_count, color_pages, mono_pages, scan_count, toner_levels
-		) VALUES (?, ?, ?, ?, ?, ?, ?)
+			serial, timestamp, page_count, color_pages, mono_pages, scan_count, toner_levels,
+			duplex_sheets, simplex_sheets, nup_sheets, counter_source, counter_confidence
+		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
 	`
 
 	// Store timestamp as RFC3339Nano UTC string to ensure consistent lexicographic
@@ -1920,7 +2148,8 @@ func (s *SQLiteStore) saveMetricsSnapshotWithExecer(ctx context.Context, ex exec
 	result, err := ex.ExecContext(ctx, query,
 		snapshot.Serial, tsStr, snapshot.PageCount,
 		snapshot.ColorPages, snapshot.MonoPages, snapshot.ScanCount,
-		string(tonerJSON),
+		string(tonerJSON), snapshot.DuplexSheets, snapshot.SimplexSheets, snapshot.NUpSheets,
+		snapshot.CounterSource, snapshot.CounterConfidence,
 	)
 
 	if err != nil {
@@ -2052,7 +2281,7 @@ func (s *SQLiteStore) GetMetricsHistory(ctx context.Context, serial string, sinc
 		SELECT id, serial, timestamp, page_count, color_pages, mono_pages, scan_count, toner_levels,
 			   fax_pages, copy_pages, other_pages, copy_mono_pages, copy_flatbed_scans, copy_adf_scans,
 			   fax_flatbed_scans, fax_adf_scans, scan_to_host_flatbed, scan_to_host_adf,
-			   duplex_sheets, jam_events, scanner_jam_events
+			   duplex_sheets, simplex_sheets, nup_sheets, jam_events, scanner_jam_events
 		FROM metrics_raw
 		WHERE serial = ? AND timestamp >= ? AND timestamp <= ?
 		ORDER BY timestamp ASC
@@ -2079,6 +2308,7 @@ func (s *SQLiteStore) GetMetricsHistory(ctx context.Context, serial string, sinc
 			&snapshot.FaxPages, &snapshot.CopyPages, &snapshot.OtherPages, &snapshot.CopyMonoPages,
 			&snapshot.CopyFlatbedScans, &snapshot.CopyADFScans, &snapshot.FaxFlatbedScans, &snapshot.FaxADFScans,
 			&snapshot.ScanToHostFlatbed, &snapshot.ScanToHostADF, &snapshot.DuplexSheets,
+			&snapshot.SimplexSheets, &snapshot.NUpSheets,
 			&snapshot.JamEvents, &snapshot.ScannerJamEvents,
 		)
 		if err != nil {
@@ -2106,7 +2336,9 @@ func (s *SQLiteStore) GetLatestMetrics(ctx context.Context, serial string) (*Met
 	}
 
 	query := `
-		SELECT id, serial, timestamp, page_count, color_pages, mono_pages, scan_count, toner_levels
+		SELECT id, serial, timestamp, page_count, color_pages, mono_pages, scan_count, toner_levels,
+			   COALESCE(duplex_sheets, 0), COALESCE(simplex_sheets, 0), COALESCE(nup_sheets, 0),
+			   COALESCE(counter_source, ''), COALESCE(counter_confidence, '')
 		FROM metrics_raw
 		WHERE serial = ?
 		ORDER BY timestamp DESC
@@ -2120,6 +2352,8 @@ func (s *SQLiteStore) GetLatestMetrics(ctx context.Context, serial string) (*Met
 		&snapshot.ID, &snapshot.Serial, &snapshot.Timestamp,
 		&snapshot.PageCount, &snapshot.ColorPages, &snapshot.MonoPages,
 		&snapshot.ScanCount, &tonerJSON,
+		&snapshot.DuplexSheets, &snapshot.SimplexSheets, &snapshot.NUpSheets,
+		&snapshot.CounterSource, &snapshot.CounterConfidence,
 	)
 
 	if err == sql.ErrNoRows {
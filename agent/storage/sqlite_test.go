@@ -2,6 +2,7 @@ package storage
 
 import (
 	"context"
+	"path/filepath"
 	"strings"
 	"testing"
 	"time"
@@ -790,3 +791,53 @@ func TestSQLiteStore_MetricsAllowsLegitimateMonoDevice(t *testing.T) {
 		t.Errorf("Mono device update was rejected! page_count=%d", latest.PageCount)
 	}
 }
+
+func TestSQLiteStore_SheetCountersSurviveReopen(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "agent.db")
+	store, err := NewSQLiteStore(dbPath)
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	ctx := context.Background()
+
+	device := newFullTestDevice("DUP001", "192.168.1.102", "HP", "LaserJet M404dn", true, true)
+	if err := store.Create(ctx, device); err != nil {
+		t.Fatalf("Failed to create device: %v", err)
+	}
+	snapshot := newTestMetrics("DUP001", 1000)
+	snapshot.ColorPages, snapshot.MonoPages = 0, 1000
+	snapshot.DuplexSheets, snapshot.SimplexSheets, snapshot.NUpSheets = 300, 400, 25
+	if err := store.SaveMetricsSnapshot(ctx, snapshot); err != nil {
+		t.Fatalf("Failed to save metrics: %v", err)
+	}
+	store.Close()
+
+	// Reopening an up-to-date database must not trigger a reset
+	store, err = NewSQLiteStore(dbPath)
+	if err != nil {
+		t.Fatalf("Failed to reopen store: %v", err)
+	}
+	defer store.Close()
+	latest, err := store.GetLatestMetrics(ctx, "DUP001")
+	if err != nil {
+		t.Fatalf("Failed to get latest after reopen: %v", err)
+	}
+	if latest.DuplexSheets != 300 || latest.SimplexSheets != 400 || latest.NUpSheets != 25 {
+		t.Errorf("sheet counters not persisted: duplex=%d simplex=%d nup=%d",
+			latest.DuplexSheets, latest.SimplexSheets, latest.NUpSheets)
+	}
+	if latest.CounterSource != "" {
+		t.Errorf("SNMP snapshot has counter source %q", latest.CounterSource)
+	}
+
+	ocr := newTestMetrics("DUP001", 1100)
+	ocr.ColorPages, ocr.MonoPages = 0, 1100
+	ocr.CounterSource, ocr.CounterConfidence = CounterSourceStatusPageOCR, "medium"
+	if err := store.SaveMetricsSnapshot(ctx, ocr); err != nil {
+		t.Fatalf("Failed to save OCR metrics: %v", err)
+	}
+	latest, err = store.GetLatestMetrics(ctx, "DUP001")
+	if err != nil || latest.CounterSource != CounterSourceStatusPageOCR || latest.CounterConfidence != "medium" {
+		t.Errorf("counter source not persisted: %+v, %v", latest, err)
+	}
+}
@@ -0,0 +1,142 @@
+package supplies
+
+// Unit is the prtMarkerSuppliesSupplyUnit value (RFC 3805 PrtMarkerSuppliesSupplyUnitTC)
+// a device reports for a supply's level and max capacity.
+type Unit int
+
+// Supply units defined by the Printer-MIB. UnitNotReported is used when the
+// device does not expose prtMarkerSuppliesSupplyUnit at all.
+const (
+	UnitNotReported            Unit = 0
+	UnitOther                  Unit = 1
+	UnitUnknown                Unit = 2
+	UnitTenThousandthsOfInches Unit = 3
+	UnitMicrometers            Unit = 4
+	UnitImpressions            Unit = 7
+	UnitSheets                 Unit = 8
+	UnitHours                  Unit = 11
+	UnitThousandthsOfOunces    Unit = 12
+	UnitTenthsOfGrams          Unit = 13
+	UnitHundredthsOfFluidOz    Unit = 14
+	UnitTenthsOfMilliliters    Unit = 15
+	UnitFeet                   Unit = 16
+	UnitMeters                 Unit = 17
+	UnitItems                  Unit = 18
+	UnitPercent                Unit = 19
+)
+
+// Percent calculation methods recorded in Reading.Method.
+const (
+	MethodCapacity       = "capacity"        // level / max capacity
+	MethodPercent        = "percent"         // device reports percent
+	MethodAssumedPercent = "assumed_percent" // no unit, level within 0-100
+	MethodSomeRemaining  = "some_remaining"  // level -3, estimated as low
+	MethodUnknown        = "unknown"         // no usable percentage
+)
+
+// SomeRemainingPercent is the estimate used when a device only reports that
+// some of a supply remains (level -3).
+const SomeRemainingPercent = 10.0
+
+// unitInfo describes how a raw unit converts to the base unit reported in
+// Reading.Value.
+type unitInfo struct {
+	name   string
+	base   string
+	factor float64
+}
+
+var units = map[Unit]unitInfo{
+	UnitOther:                  {"other", "", 0},
+	UnitUnknown:                {"unknown", "", 0},
+	UnitTenThousandthsOfInches: {"tenThousandthsOfInches", "m", 0.0000254},
+	UnitMicrometers:            {"micrometers", "m", 0.000001},
+	UnitImpressions:            {"impressions", "pages", 1},
+	UnitSheets:                 {"sheets", "pages", 1},
+	UnitHours:                  {"hours", "h", 1},
+	UnitThousandthsOfOunces:    {"thousandthsOfOunces", "g", 0.0283495},
+	UnitTenthsOfGrams:          {"tenthsOfGrams", "g", 0.1},
+	UnitHundredthsOfFluidOz:    {"hundredthsOfFluidOunces", "ml", 0.295735},
+	UnitTenthsOfMilliliters:    {"tenthsOfMilliliters", "ml", 0.1},
+	UnitFeet:                   {"feet", "m", 0.3048},
+	UnitMeters:                 {"meters", "m", 1},
+	UnitItems:                  {"items", "items", 1},
+	UnitPercent:                {"percent", "%", 1},
+}
+
+// String returns the Printer-MIB name of the unit, or "" when not reported.
+func (u Unit) String() string {
+	if info, ok := units[u]; ok {
+		return info.name
+	}
+	if u == UnitNotReported {
+		return ""
+	}
+	return "other"
+}
+
+// Absolute reports whether levels in this unit are an amount (grams, pages,
+// ...) rather than a percentage or an unspecified scale.
+func (u Unit) Absolute() bool {
+	info, ok := units[u]
+	return ok && info.base != "" && u != UnitPercent
+}
+
+// Reading is one supply level as reported by the device together with the
+// normalized view used for charts and alerts.
+type Reading struct {
+	Level       int     `json:"level"`                // raw prtMarkerSuppliesLevel
+	MaxCapacity int     `json:"max_capacity"`         // raw prtMarkerSuppliesMaxCapacity
+	Unit        string  `json:"unit,omitempty"`       // Printer-MIB unit name
+	Value       float64 `json:"value,omitempty"`      // remaining amount in ValueUnit
+	ValueUnit   string  `json:"value_unit,omitempty"` // pages, g, ml, m, h, items or %
+	Percent     float64 `json:"percent"`              // 0-100, or -1 when unknown
+	Method      string  `json:"method"`
+}
+
+// HasPercent reports whether the reading carries a usable percentage.
+func (r Reading) HasPercent() bool {
+	return r.Percent >= 0
+}
+
+// Normalize converts a raw level and max capacity in the given unit into a
+// Reading. The percentage is taken from the max capacity when the device
+// reports one; otherwise a level is only read as a percentage when the unit
+// is percent, or when no unit is reported and the level is within 0-100.
+// Absolute levels without a capacity (e.g. grams of toner) have no
+// percentage, so they are not mistaken for one.
+//
+// Negative levels and capacities are the RFC 3805 special values:
+// -1 other, -2 unknown, -3 some remaining.
+func Normalize(level, maxCapacity int, unit Unit) Reading {
+	r := Reading{
+		Level:       level,
+		MaxCapacity: maxCapacity,
+		Unit:        unit.String(),
+		Percent:     -1,
+		Method:      MethodUnknown,
+	}
+	if info, ok := units[unit]; ok && info.base != "" && level >= 0 {
+		r.Value = float64(level) * info.factor
+		r.ValueUnit = info.base
+	}
+
+	switch {
+	case maxCapacity > 0 && level >= 0:
+		r.Percent = float64(level) / float64(maxCapacity) * 100.0
+		if r.Percent > 100 {
+			r.Percent = 100
+		}
+		r.Method = MethodCapacity
+	case level >= 0 && level <= 100 && unit == UnitPercent:
+		r.Percent = float64(level)
+		r.Method = MethodPercent
+	case level >= 0 && level <= 100 && !unit.Absolute():
+		r.Percent = float64(level)
+		r.Method = MethodAssumedPercent
+	case level == -3:
+		r.Percent = SomeRemainingPercent
+		r.Method = MethodSomeRemaining
+	}
+	return r
+}
@@ -0,0 +1,65 @@
+package supplies
+
+import "testing"
+
+func TestNormalize(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name      string
+		level     int
+		max       int
+		unit      Unit
+		percent   float64
+		method    string
+		value     float64
+		valueUnit string
+	}{
+		{"capacity in grams", 1500, 6000, UnitTenthsOfGrams, 25, MethodCapacity, 150, "g"},
+		{"capacity in impressions", 3000, 12000, UnitImpressions, 25, MethodCapacity, 3000, "pages"},
+		{"capacity without unit", 40, 100, UnitNotReported, 40, MethodCapacity, 0, ""},
+		{"level above capacity clamps", 110, 100, UnitPercent, 100, MethodCapacity, 110, "%"},
+		{"percent without capacity", 55, -2, UnitPercent, 55, MethodPercent, 55, "%"},
+		{"no unit assumes percent", 80, -1, UnitNotReported, 80, MethodAssumedPercent, 0, ""},
+		{"unknown unit assumes percent", 80, -2, UnitUnknown, 80, MethodAssumedPercent, 0, ""},
+		{"grams without capacity", 45, -2, UnitTenthsOfGrams, -1, MethodUnknown, 4.5, "g"},
+		{"pages without capacity", 90, -1, UnitSheets, -1, MethodUnknown, 90, "pages"},
+		{"some remaining", -3, -2, UnitTenthsOfGrams, SomeRemainingPercent, MethodSomeRemaining, 0, ""},
+		{"unknown level", -2, 100, UnitPercent, -1, MethodUnknown, 0, ""},
+		{"no unit out of range", 250, -1, UnitNotReported, -1, MethodUnknown, 0, ""},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			r := Normalize(tt.level, tt.max, tt.unit)
+			if r.Percent != tt.percent || r.Method != tt.method {
+				t.Errorf("Normalize(%d, %d, %v) percent=%v method=%q, want %v %q", tt.level, tt.max, tt.unit, r.Percent, r.Method, tt.percent, tt.method)
+			}
+			if diff := r.Value - tt.value; diff > 1e-9 || diff < -1e-9 || r.ValueUnit != tt.valueUnit {
+				t.Errorf("Normalize(%d, %d, %v) value=%v %q, want %v %q", tt.level, tt.max, tt.unit, r.Value, r.ValueUnit, tt.value, tt.valueUnit)
+			}
+			if r.Level != tt.level || r.MaxCapacity != tt.max {
+				t.Errorf("raw values not kept: %+v", r)
+			}
+		})
+	}
+}
+
+func TestUnitString(t *testing.T) {
+	t.Parallel()
+
+	if got := UnitTenthsOfGrams.String(); got != "tenthsOfGrams" {
+		t.Errorf("UnitTenthsOfGrams.String() = %q", got)
+	}
+	if got := UnitNotReported.String(); got != "" {
+		t.Errorf("UnitNotReported.String() = %q", got)
+	}
+	if got := Unit(99).String(); got != "other" {
+		t.Errorf("Unit(99).String() = %q", got)
+	}
+	if UnitPercent.Absolute() || UnitUnknown.Absolute() || !UnitSheets.Absolute() {
+		t.Error("Absolute() misclassifies units")
+	}
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"math/rand/v2"
+	"sync"
+	"time"
+)
+
+// defaultScheduleJitter is the fraction of an interval added as random delay
+// to each scheduled heartbeat or upload.
+const defaultScheduleJitter = 0.1
+
+// loopSchedule spreads a periodic task across the fleet. Runs are aligned to
+// a per-agent offset within the interval (measured from the Unix epoch, so
+// agents with different offsets stay apart) and delayed by a random jitter so
+// agents sharing an offset do not fire in lockstep. The offset starts out
+// random and is replaced by the server's suggestion once one arrives.
+type loopSchedule struct {
+	mu       sync.Mutex
+	interval time.Duration
+	offset   time.Duration
+	jitter   time.Duration // Maximum random delay added to each run
+	fromHint bool          // offset was suggested by the server
+}
+
+func newLoopSchedule(interval time.Duration, jitterFraction float64) *loopSchedule {
+	s := &loopSchedule{interval: interval}
+	if interval > 0 {
+		s.offset = rand.N(interval)
+		if jitterFraction > 0 && jitterFraction < 1 {
+			s.jitter = time.Duration(float64(interval) * jitterFraction)
+		}
+	}
+	return s
+}
+
+// setOffset applies a server-suggested offset, normalized into the interval.
+// It reports whether the offset changed.
+func (s *loopSchedule) setOffset(offset time.Duration) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.interval <= 0 {
+		return false
+	}
+	offset %= s.interval
+	if offset < 0 {
+		offset += s.interval
+	}
+	changed := !s.fromHint || offset != s.offset
+	s.offset = offset
+	s.fromHint = true
+	return changed
+}
+
+// next returns how long to wait after now before the next run: the time to
+// the next slot at offset within the interval, plus random jitter.
+func (s *loopSchedule) next(now time.Time) time.Duration {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.interval <= 0 {
+		return time.Minute
+	}
+	delay := s.offset - time.Duration(now.UnixNano()%int64(s.interval))
+	if delay <= 0 {
+		delay += s.interval
+	}
+	if s.jitter > 0 {
+		delay += rand.N(s.jitter)
+	}
+	return delay
+}
+
+// current returns the offset in use and whether it came from the server.
+func (s *loopSchedule) current() (time.Duration, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.offset, s.fromHint
+}
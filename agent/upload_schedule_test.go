@@ -0,0 +1,70 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	agentpkg "printmaster/agent/agent"
+)
+
+func TestLoopScheduleAlignsToOffset(t *testing.T) {
+	t.Parallel()
+	s := newLoopSchedule(5*time.Minute, -1)
+	if !s.setOffset(90 * time.Second) {
+		t.Fatal("expected first server offset to count as a change")
+	}
+	if s.setOffset(90*time.Second + 5*time.Minute) {
+		t.Fatal("expected equivalent offset to be a no-op")
+	}
+
+	base := time.Unix(1_800_000_000, 0) // A multiple of 5 minutes
+	cases := []struct {
+		now  time.Time
+		want time.Duration
+	}{
+		{base, 90 * time.Second},
+		{base.Add(30 * time.Second), time.Minute},
+		{base.Add(90 * time.Second), 5 * time.Minute}, // Just ran: wait a full interval
+		{base.Add(4 * time.Minute), 2*time.Minute + 30*time.Second},
+	}
+	for _, tc := range cases {
+		if got := s.next(tc.now); got != tc.want {
+			t.Errorf("next(%v) = %v, want %v", tc.now.Sub(base), got, tc.want)
+		}
+	}
+}
+
+func TestLoopScheduleJitterBounds(t *testing.T) {
+	t.Parallel()
+	s := newLoopSchedule(time.Minute, 0.1)
+	s.setOffset(0)
+	base := time.Unix(1_800_000_000, 0)
+	for i := 0; i < 200; i++ {
+		got := s.next(base.Add(time.Second))
+		if got < 59*time.Second || got >= 65*time.Second {
+			t.Fatalf("next = %v, want within [59s, 65s)", got)
+		}
+	}
+
+	// Local offsets before a server hint are random but within the interval
+	for i := 0; i < 50; i++ {
+		offset, fromServer := newLoopSchedule(time.Minute, 0.1).current()
+		if offset < 0 || offset >= time.Minute || fromServer {
+			t.Fatalf("initial offset = %v (server=%v)", offset, fromServer)
+		}
+	}
+}
+
+func TestUploadWorkerAppliesScheduleHint(t *testing.T) {
+	t.Parallel()
+	worker := NewUploadWorker(nil, nil, stubLogger{}, nil, UploadWorkerConfig{}, "")
+	worker.applyScheduleHint(&agentpkg.ScheduleHint{UploadOffsetSeconds: 42, HeartbeatOffsetSeconds: 7})
+
+	if offset, fromServer := worker.uploadSchedule.current(); offset != 42*time.Second || !fromServer {
+		t.Errorf("upload offset = %v (server=%v), want 42s from server", offset, fromServer)
+	}
+	if offset, _ := worker.heartbeatSchedule.current(); offset != 7*time.Second {
+		t.Errorf("heartbeat offset = %v, want 7s", offset)
+	}
+	worker.applyScheduleHint(nil)
+}
@@ -2,6 +2,7 @@ package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"net/http"
 	"os"
@@ -12,7 +13,10 @@ import (
 	"time"
 
 	"printmaster/agent/agent"
+	"printmaster/agent/featureflags"
 	"printmaster/agent/storage"
+	"printmaster/common/meterread"
+	commonstorage "printmaster/common/storage"
 )
 
 // Logger interface for upload worker operations
@@ -39,6 +43,9 @@ type UploadWorker struct {
 	useWebSocket bool
 	wsClientMu   sync.RWMutex
 
+	// MQTT transport (optional, replaces HTTP and WebSocket when set)
+	mqtt mqttTransport
+
 	// Local handler for proxy requests (stored here until wsClient is created)
 	pendingLocalHandler   http.Handler
 	pendingLocalHandlerMu sync.RWMutex
@@ -49,6 +56,16 @@ type UploadWorker struct {
 	retryAttempts     int
 	retryBackoff      time.Duration
 
+	// Staggered scheduling for heartbeats and uploads
+	heartbeatSchedule *loopSchedule
+	uploadSchedule    *loopSchedule
+
+	// Circuit breaker pausing server traffic while it keeps failing, and
+	// kicks used by RetryNow to run the loops immediately
+	breaker       *agent.CircuitBreaker
+	heartbeatKick chan struct{}
+	uploadKick    chan struct{}
+
 	// State tracking
 	mu                sync.RWMutex
 	lastHeartbeat     time.Time
@@ -56,6 +73,10 @@ type UploadWorker struct {
 	lastMetricsUpload time.Time
 	running           bool
 
+	// Parse samples sent per device IP, so each is sent at most once per
+	// parseSampleInterval
+	parseSamplesSent map[string]time.Time
+
 	// Lifecycle
 	stopCh chan struct{}
 	wg     sync.WaitGroup
@@ -69,6 +90,10 @@ type UploadWorkerStatus struct {
 	LastMetricsUpload  time.Time `json:"last_metrics_upload"`
 	WebSocketEnabled   bool      `json:"websocket_enabled"`
 	WebSocketConnected bool      `json:"websocket_connected"`
+	Transport          string    `json:"transport"`
+	MQTTConnected      bool      `json:"mqtt_connected"`
+
+	Health agent.ServerHealthSnapshot `json:"health"`
 }
 
 // Status returns snapshot information about the worker lifecycle and recent activity.
@@ -83,8 +108,13 @@ func (w *UploadWorker) Status() UploadWorkerStatus {
 		LastDeviceUpload:  w.lastDeviceUpload,
 		LastMetricsUpload: w.lastMetricsUpload,
 		WebSocketEnabled:  w.useWebSocket,
+		Transport:         w.transport(),
 	}
 	w.mu.RUnlock()
+	if w.mqtt != nil {
+		status.MQTTConnected = w.mqtt.IsConnected()
+	}
+	status.Health = w.breaker.Snapshot()
 	w.wsClientMu.RLock()
 	wsClient := w.wsClient
 	w.wsClientMu.RUnlock()
@@ -146,7 +176,7 @@ func (w *UploadWorker) handleHeartbeatSettings(result *agent.HeartbeatResult) {
 		return
 	}
 	newVersion := strings.TrimSpace(result.Snapshot.Version)
-	if newVersion == "" || newVersion == w.settings.CurrentVersion() {
+	if newVersion == "" || (newVersion == w.settings.CurrentVersion() && !result.Enforce) {
 		return
 	}
 	effective, err := w.settings.ApplyServerSnapshot(result.Snapshot)
@@ -155,15 +185,60 @@ func (w *UploadWorker) handleHeartbeatSettings(result *agent.HeartbeatResult) {
 		return
 	}
 	applyEffectiveSettingsSnapshot(effective)
+	if result.Enforce {
+		w.logger.Info("Re-applied server settings policy", "version", newVersion)
+	}
+}
+
+// applyScheduleHint adopts the server-suggested offsets for upload and
+// heartbeat timing. Timers pick them up on their next reset.
+func (w *UploadWorker) applyScheduleHint(hint *agent.ScheduleHint) {
+	if hint == nil || w.uploadSchedule == nil || w.heartbeatSchedule == nil {
+		return
+	}
+	uploadOffset := time.Duration(hint.UploadOffsetSeconds) * time.Second
+	heartbeatOffset := time.Duration(hint.HeartbeatOffsetSeconds) * time.Second
+	uploadChanged := w.uploadSchedule.setOffset(uploadOffset)
+	heartbeatChanged := w.heartbeatSchedule.setOffset(heartbeatOffset)
+	if uploadChanged || heartbeatChanged {
+		w.logger.Debug("Applied server schedule offsets",
+			"upload_offset", uploadOffset,
+			"heartbeat_offset", heartbeatOffset)
+	}
 }
 
+// maxThrottleBackoff caps how long a single retry waits on a server's
+// Retry-After hint before the attempt is left to the next scheduled run.
+const maxThrottleBackoff = 5 * time.Minute
+
 // UploadWorkerConfig contains configuration for the upload worker
 type UploadWorkerConfig struct {
 	HeartbeatInterval time.Duration
 	UploadInterval    time.Duration
 	RetryAttempts     int
 	RetryBackoff      time.Duration
-	UseWebSocket      bool // Enable WebSocket for heartbeats
+	UseWebSocket      bool          // Enable WebSocket for heartbeats
+	JitterFraction    float64       // Random delay added per run, as a fraction of the interval (default 0.1, negative disables)
+	MQTT              mqttTransport // Publish over MQTT instead of HTTP/WebSocket when set
+}
+
+// mqttTransport is the broker client used when the agent talks to the server
+// over MQTT. Implemented by agent.MQTTClient.
+type mqttTransport interface {
+	Start() error
+	Stop()
+	IsConnected() bool
+	Broker() string
+	Publish(ctx context.Context, kind string, payload interface{}) error
+	PublishBatch(ctx context.Context, kind, field string, items []interface{}) error
+}
+
+// transport names the server transport in status output.
+func (w *UploadWorker) transport() string {
+	if w.mqtt != nil {
+		return "mqtt"
+	}
+	return "http"
 }
 
 // NewUploadWorker creates a new upload worker instance
@@ -181,6 +256,9 @@ func NewUploadWorker(client *agent.ServerClient, store storage.DeviceStore, logg
 	if config.RetryBackoff == 0 {
 		config.RetryBackoff = 2 * time.Second
 	}
+	if config.JitterFraction == 0 {
+		config.JitterFraction = defaultScheduleJitter
+	}
 
 	w := &UploadWorker{
 		client:            client,
@@ -192,9 +270,19 @@ func NewUploadWorker(client *agent.ServerClient, store storage.DeviceStore, logg
 		uploadInterval:    config.UploadInterval,
 		retryAttempts:     config.RetryAttempts,
 		retryBackoff:      config.RetryBackoff,
-		useWebSocket:      config.UseWebSocket,
+		heartbeatSchedule: newLoopSchedule(config.HeartbeatInterval, config.JitterFraction),
+		uploadSchedule:    newLoopSchedule(config.UploadInterval, config.JitterFraction),
+		useWebSocket:      config.UseWebSocket && config.MQTT == nil,
+		mqtt:              config.MQTT,
+		breaker:           agent.NewCircuitBreaker(),
+		heartbeatKick:     make(chan struct{}, 1),
+		uploadKick:        make(chan struct{}, 1),
 		stopCh:            make(chan struct{}),
 	}
+	if client != nil {
+		client.SetScheduleIntervals(config.UploadInterval, config.HeartbeatInterval)
+		client.SetRuntimeEnvironment(string(agentRuntimeEnv))
+	}
 
 	return w
 }
@@ -209,8 +297,12 @@ func (w *UploadWorker) StartWithVersionInfo(ctx context.Context, version string,
 	// Store version info for heartbeats
 	w.versionInfo = versionInfo
 
-	// Ensure agent is registered first
-	if err := w.ensureRegistered(ctx, version); err != nil {
+	// The broker authenticates MQTT agents, so there is no server registration
+	if w.mqtt != nil {
+		if err := w.mqtt.Start(); err != nil {
+			return fmt.Errorf("mqtt client failed to start: %w", err)
+		}
+	} else if err := w.ensureRegistered(ctx, version); err != nil {
 		return fmt.Errorf("registration failed: %w", err)
 	}
 
@@ -221,6 +313,8 @@ func (w *UploadWorker) StartWithVersionInfo(ctx context.Context, version string,
 
 		w.wsClientMu.Lock()
 		w.wsClient = agent.NewWSClient(serverURL, token, w.client.IsInsecureSkipVerify())
+		w.wsClient.SetTokenHandler(w.adoptServerToken)
+		w.wsClient.SetRelayHandler(w.client.OfferRelays)
 		w.wsClientMu.Unlock()
 
 		// Apply pending local handler if one was set before wsClient existed
@@ -257,6 +351,7 @@ func (w *UploadWorker) StartWithVersionInfo(ctx context.Context, version string,
 	w.logger.Info("Upload worker started",
 		"heartbeat_interval", w.heartbeatInterval,
 		"upload_interval", w.uploadInterval,
+		"transport", w.transport(),
 		"websocket_enabled", w.useWebSocket)
 
 	return nil
@@ -276,6 +371,9 @@ func (w *UploadWorker) Stop() {
 	w.wsClientMu.Unlock()
 
 	w.wg.Wait()
+	if w.mqtt != nil {
+		w.mqtt.Stop()
+	}
 	w.mu.Lock()
 	w.running = false
 	w.mu.Unlock()
@@ -390,15 +488,18 @@ func (w *UploadWorker) ensureRegistered(ctx context.Context, version string) err
 func (w *UploadWorker) heartbeatLoop() {
 	defer w.wg.Done()
 
-	ticker := time.NewTicker(w.heartbeatInterval)
-	defer ticker.Stop()
-
-	// Send initial heartbeat immediately
+	// Send initial heartbeat immediately, then follow the staggered schedule
 	w.sendHeartbeat()
 
+	timer := time.NewTimer(w.heartbeatSchedule.next(time.Now()))
+	defer timer.Stop()
+
 	for {
 		select {
-		case <-ticker.C:
+		case <-timer.C:
+			w.sendHeartbeat()
+			timer.Reset(w.heartbeatSchedule.next(time.Now()))
+		case <-w.heartbeatKick:
 			w.sendHeartbeat()
 		case <-w.stopCh:
 			return
@@ -410,12 +511,16 @@ func (w *UploadWorker) heartbeatLoop() {
 // heartbeats keep server-side agent records in sync.
 func (w *UploadWorker) buildHeartbeatMetadata() map[string]interface{} {
 	meta := map[string]interface{}{
-		"status":       "active",
-		"platform":     agent.GetPlatformInfo(),
-		"os_version":   agent.GetOSVersionDetailed(),
-		"architecture": runtime.GOARCH,
-		"go_version":   runtime.Version(),
-		"num_cpu":      runtime.NumCPU(),
+		"status":              "active",
+		"platform":            agent.GetPlatformInfo(),
+		"os_version":          agent.GetOSVersionDetailed(),
+		"architecture":        runtime.GOARCH,
+		"go_version":          runtime.Version(),
+		"num_cpu":             runtime.NumCPU(),
+		"runtime_environment": string(agentRuntimeEnv),
+	}
+	if key := w.client.MeterReadPublicKey(); key != "" {
+		meta["meter_read_public_key"] = key
 	}
 
 	// Add runtime memory stats
@@ -460,6 +565,11 @@ func (w *UploadWorker) sendHeartbeat() {
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
+	if w.mqtt != nil {
+		w.sendMQTTHeartbeat(ctx)
+		return
+	}
+
 	// Try WebSocket first if available
 	w.wsClientMu.RLock()
 	wsClient := w.wsClient
@@ -477,14 +587,24 @@ func (w *UploadWorker) sendHeartbeat() {
 		// Send heartbeat over WebSocket with metadata parity to HTTP heartbeats
 		heartbeatData := w.buildHeartbeatMetadata()
 		heartbeatData["device_count"] = deviceCount
+		heartbeatData["token_rotation"] = true
+		heartbeatData["token_rotation_nonce"] = w.client.RotationNonce()
 		if settingsVersion := strings.TrimSpace(w.currentSettingsVersion()); settingsVersion != "" {
 			heartbeatData["settings_version"] = settingsVersion
 		}
+		if effective := reportedEffectiveSettings(); effective != nil {
+			heartbeatData["effective_settings"] = effective
+		}
+		if relayID, rtt := w.client.RelayReport(); relayID != 0 {
+			heartbeatData["relay_id"] = relayID
+			heartbeatData["relay_rtt_ms"] = rtt
+		}
 
 		if err := wsClient.SendHeartbeat(heartbeatData); err != nil {
 			w.logger.Warn("WebSocket heartbeat failed, falling back to HTTP", "error", err)
 			// Fall through to HTTP heartbeat
 		} else {
+			w.recordServerResult(nil)
 			w.mu.Lock()
 			w.lastHeartbeat = time.Now()
 			w.mu.Unlock()
@@ -494,6 +614,64 @@ func (w *UploadWorker) sendHeartbeat() {
 	}
 
 	// Fall back to HTTP heartbeat
+	w.sendHTTPHeartbeat(ctx)
+}
+
+// sendMQTTHeartbeat publishes the heartbeat metadata to the agent's
+// heartbeat topic. MQTT heartbeats get no reply, so server settings and
+// token rotation are not applied in this mode.
+func (w *UploadWorker) sendMQTTHeartbeat(ctx context.Context) {
+	if !w.breaker.Allow() {
+		w.logger.Debug("Skipping heartbeat while server circuit breaker is open")
+		return
+	}
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	visibleOnly := true
+	deviceCount := 0
+	if devices, err := w.store.List(ctx, storage.DeviceFilter{Visible: &visibleOnly}); err == nil {
+		deviceCount = len(devices)
+	}
+	heartbeatData := w.buildHeartbeatMetadata()
+	heartbeatData["agent_id"] = w.client.AgentID
+	heartbeatData["timestamp"] = time.Now().UTC()
+	heartbeatData["device_count"] = deviceCount
+	if effective := reportedEffectiveSettings(); effective != nil {
+		heartbeatData["effective_settings"] = effective
+	}
+
+	if err := w.mqtt.Publish(ctx, agent.MQTTTopicHeartbeat, heartbeatData); err != nil {
+		w.recordServerResult(err)
+		w.logger.Warn("MQTT heartbeat failed", "error", err)
+		return
+	}
+	w.recordServerResult(nil)
+	w.mu.Lock()
+	w.lastHeartbeat = time.Now()
+	w.mu.Unlock()
+	w.logger.Debug("Heartbeat sent via MQTT")
+}
+
+// syncSettings sends an HTTP heartbeat right away so a policy the server
+// re-enforced is applied without waiting for the next interval. WebSocket
+// heartbeats do not carry settings.
+func (w *UploadWorker) syncSettings() {
+	if w.mqtt != nil {
+		w.logger.Info("Settings sync is not available over MQTT; server settings require the HTTP transport")
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	w.sendHTTPHeartbeat(ctx)
+}
+
+func (w *UploadWorker) sendHTTPHeartbeat(ctx context.Context) {
+	if !w.breaker.Allow() {
+		w.logger.Debug("Skipping heartbeat while server circuit breaker is open")
+		return
+	}
+	w.client.SetEffectiveSettings(reportedEffectiveSettings())
 	var hbResult *agent.HeartbeatResult
 	err := w.retryWithBackoff(func() error {
 		result, err := w.client.HeartbeatWithVersion(ctx, w.currentSettingsVersion(), w.versionInfo)
@@ -508,6 +686,8 @@ func (w *UploadWorker) sendHeartbeat() {
 	} else {
 		if hbResult != nil {
 			w.handleHeartbeatSettings(hbResult)
+			w.applyScheduleHint(hbResult.Schedule)
+			w.adoptServerToken(hbResult.AgentToken, hbResult.AgentTokenExpiresAt)
 		}
 		w.mu.Lock()
 		w.lastHeartbeat = time.Now()
@@ -516,19 +696,44 @@ func (w *UploadWorker) sendHeartbeat() {
 	}
 }
 
+// adoptServerToken switches to a token rotated by the server. The token is
+// written to disk first: if that fails the old token stays in use and the
+// server sends the new one again on the next heartbeat.
+func (w *UploadWorker) adoptServerToken(token string, expiresAt time.Time) {
+	if token == "" || token == w.client.GetToken() {
+		return
+	}
+	if w.dataDir != "" {
+		if err := SaveServerToken(w.dataDir, token); err != nil {
+			w.logger.Error("Failed to persist rotated agent token", "error", err)
+			return
+		}
+	}
+	w.client.SetToken(token)
+	w.wsClientMu.RLock()
+	if w.wsClient != nil {
+		w.wsClient.SetToken(token)
+	}
+	w.wsClientMu.RUnlock()
+	w.logger.Info("Agent token rotated by server", "expires_at", expiresAt)
+}
+
 // uploadLoop handles periodic uploads of devices and metrics
 func (w *UploadWorker) uploadLoop() {
 	defer w.wg.Done()
 
-	ticker := time.NewTicker(w.uploadInterval)
-	defer ticker.Stop()
-
 	// Upload immediately on start (don't wait for first interval)
 	w.doUpload()
 
+	timer := time.NewTimer(w.uploadSchedule.next(time.Now()))
+	defer timer.Stop()
+
 	for {
 		select {
-		case <-ticker.C:
+		case <-timer.C:
+			w.doUpload()
+			timer.Reset(w.uploadSchedule.next(time.Now()))
+		case <-w.uploadKick:
 			w.doUpload()
 		case <-w.stopCh:
 			return
@@ -538,6 +743,11 @@ func (w *UploadWorker) uploadLoop() {
 
 // doUpload performs a complete upload cycle (devices + metrics)
 func (w *UploadWorker) doUpload() {
+	defer watchdogBegin("upload")()
+	if !w.breaker.Allow() {
+		w.logger.Debug("Skipping upload cycle while server circuit breaker is open")
+		return
+	}
 	w.logger.Debug("Starting upload cycle")
 
 	// Upload devices first
@@ -546,11 +756,31 @@ func (w *UploadWorker) doUpload() {
 		// Continue to metrics even if devices failed (partial success OK)
 	}
 
-	// Then upload metrics
+	// Then upload metrics, unless the device upload just tripped the breaker
+	if !w.breaker.Allow() {
+		return
+	}
 	if err := w.uploadMetrics(); err != nil {
 		w.logger.Error("Metrics upload failed", "error", err)
 	}
 
+	// Certified meter reads are kept locally until the server acknowledges them
+	if !w.breaker.Allow() {
+		return
+	}
+	if err := w.uploadMeterReads(); err != nil {
+		w.logger.Error("Meter read upload failed", "error", err)
+	}
+
+	// Parse samples are diagnostics: failures (e.g. an older server without
+	// the endpoint) are logged quietly and don't count against the breaker
+	if err := w.uploadParseSamples(); err != nil {
+		w.logger.Debug("Parse sample upload failed", "error", err)
+	}
+	if err := w.uploadUnknownDevices(); err != nil {
+		w.logger.Debug("Unknown device upload failed", "error", err)
+	}
+
 	w.logger.Debug("Upload cycle complete")
 }
 
@@ -614,6 +844,9 @@ func (w *UploadWorker) uploadDevices() error {
 
 	// Upload with retry
 	err = w.retryWithBackoff(func() error {
+		if w.mqtt != nil {
+			return w.mqtt.PublishBatch(ctx, agent.MQTTTopicDevices, "devices", deviceMaps)
+		}
 		return w.client.UploadDevices(ctx, deviceMaps)
 	})
 
@@ -661,13 +894,20 @@ func (w *UploadWorker) uploadMetrics() error {
 		}
 
 		metricMap := map[string]interface{}{
-			"serial":       metrics.Serial,
-			"timestamp":    metrics.Timestamp,
-			"page_count":   metrics.PageCount,
-			"color_pages":  metrics.ColorPages,
-			"mono_pages":   metrics.MonoPages,
-			"scan_count":   metrics.ScanCount,
-			"toner_levels": metrics.TonerLevels,
+			"serial":         metrics.Serial,
+			"timestamp":      metrics.Timestamp,
+			"page_count":     metrics.PageCount,
+			"color_pages":    metrics.ColorPages,
+			"mono_pages":     metrics.MonoPages,
+			"scan_count":     metrics.ScanCount,
+			"duplex_sheets":  metrics.DuplexSheets,
+			"simplex_sheets": metrics.SimplexSheets,
+			"nup_sheets":     metrics.NUpSheets,
+			"toner_levels":   metrics.TonerLevels,
+		}
+		if metrics.CounterSource != "" {
+			metricMap["counter_source"] = metrics.CounterSource
+			metricMap["counter_confidence"] = metrics.CounterConfidence
 		}
 		metricMaps = append(metricMaps, metricMap)
 	}
@@ -679,6 +919,9 @@ func (w *UploadWorker) uploadMetrics() error {
 
 	// Upload with retry
 	err = w.retryWithBackoff(func() error {
+		if w.mqtt != nil {
+			return w.mqtt.PublishBatch(ctx, agent.MQTTTopicMetrics, "metrics", metricMaps)
+		}
 		return w.client.UploadMetrics(ctx, metricMaps)
 	})
 
@@ -694,13 +937,193 @@ func (w *UploadWorker) uploadMetrics() error {
 	return nil
 }
 
-// retryWithBackoff retries a function with exponential backoff
+// uploadMeterReads uploads certified meter reads that have not yet been
+// acknowledged by the server. Reads the server rejects are not resent; those
+// whose signature it refused are quarantined rather than marked uploaded, so
+// the certified counters stay on the agent. Over MQTT the broker's
+// acknowledgement counts as acceptance.
+func (w *UploadWorker) uploadMeterReads() error {
+	store, ok := w.store.(storage.MeterReadStore)
+	if !ok {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+	defer cancel()
+
+	reads, err := store.ListPendingMeterReads(ctx, 500)
+	if err != nil {
+		return fmt.Errorf("failed to list pending meter reads: %w", err)
+	}
+	if len(reads) == 0 {
+		return nil
+	}
+
+	payload := make([]interface{}, 0, len(reads))
+	for _, read := range reads {
+		payload = append(payload, read)
+	}
+	var result *agent.MeterReadUploadResult
+	err = w.retryWithBackoff(func() error {
+		if w.mqtt != nil {
+			if perr := w.mqtt.PublishBatch(ctx, agent.MQTTTopicMeterReads, "reads", payload); perr != nil {
+				return perr
+			}
+			result = &agent.MeterReadUploadResult{}
+			for _, read := range reads {
+				result.Accepted = append(result.Accepted, read.ID)
+			}
+			return nil
+		}
+		var uerr error
+		result, uerr = w.client.UploadMeterReads(ctx, payload)
+		return uerr
+	})
+	if err != nil {
+		return fmt.Errorf("failed to upload meter reads: %w", err)
+	}
+
+	now := time.Now()
+	done := append([]string{}, result.Accepted...)
+	for _, rej := range result.Rejected {
+		if meterread.IsSignatureRejection(rej.Reason) {
+			w.logger.Error("Server refused meter read signature; quarantining read", "id", rej.ID, "reason", rej.Reason)
+			if err := store.QuarantineMeterRead(ctx, rej.ID, rej.Reason, now); err != nil {
+				return err
+			}
+			continue
+		}
+		w.logger.Warn("Server rejected meter read", "id", rej.ID, "reason", rej.Reason)
+		done = append(done, rej.ID)
+	}
+	if err := store.MarkMeterReadsUploaded(ctx, done, now); err != nil {
+		return err
+	}
+
+	w.logger.Info("Meter reads uploaded", "accepted", len(result.Accepted), "rejected", len(result.Rejected))
+	return nil
+}
+
+// parseSampleInterval is how often the parse-debug sample for one device is
+// re-sent while it still has parsing gaps.
+const parseSampleInterval = 24 * time.Hour
+
+// maxParseSamplePDUs caps the raw PDUs sent per sample.
+const maxParseSamplePDUs = 2000
+
+// uploadParseSamples sends the parse-debug snapshot of devices the parser
+// could not fully read (stand-in serial, no model, toner or counters) to the
+// server's data quality dashboard. Only sent over HTTP.
+func (w *UploadWorker) uploadParseSamples() error {
+	if w.mqtt != nil || w.client == nil {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+	defer cancel()
+
+	visibleTrue := true
+	devices, err := w.store.List(ctx, storage.DeviceFilter{Visible: &visibleTrue})
+	if err != nil {
+		return fmt.Errorf("failed to list devices: %w", err)
+	}
+
+	now := time.Now()
+	var samples []interface{}
+	var sentIPs []string
+	for _, dev := range devices {
+		if dev.IP == "" {
+			continue
+		}
+		w.mu.RLock()
+		last, sent := w.parseSamplesSent[dev.IP]
+		w.mu.RUnlock()
+		if sent && now.Sub(last) < parseSampleInterval {
+			continue
+		}
+		pageCount := 0
+		var toner map[string]interface{}
+		if m, err := w.store.GetLatestMetrics(ctx, dev.Serial); err == nil && m != nil {
+			pageCount, toner = m.PageCount, m.TonerLevels
+		}
+		gaps := commonstorage.ParseGaps(&dev.Device, pageCount, toner)
+		if len(gaps) == 0 {
+			continue
+		}
+		pd, ok := agent.GetParseDebug(dev.IP)
+		if !ok {
+			continue
+		}
+		pdus := pd.RawPDUs
+		if len(pd.FullWalkData) > len(pdus) {
+			pdus = pd.FullWalkData
+		}
+		if len(pdus) > maxParseSamplePDUs {
+			pdus = pdus[:maxParseSamplePDUs]
+		}
+		sysObjectID, _ := dev.RawData["sys_object_id"].(string)
+		samples = append(samples, map[string]interface{}{
+			"ip":            dev.IP,
+			"serial":        dev.Serial,
+			"manufacturer":  dev.Manufacturer,
+			"model":         dev.Model,
+			"sys_object_id": sysObjectID,
+			"missing":       gaps,
+			"steps":         pd.Steps,
+			"raw_pdus":      pdus,
+			"captured_at":   pd.Timestamp,
+		})
+		sentIPs = append(sentIPs, dev.IP)
+	}
+	if len(samples) == 0 {
+		return nil
+	}
+
+	if err := w.client.UploadParseSamples(ctx, samples); err != nil {
+		return err
+	}
+	w.mu.Lock()
+	if w.parseSamplesSent == nil {
+		w.parseSamplesSent = make(map[string]time.Time)
+	}
+	for _, ip := range sentIPs {
+		w.parseSamplesSent[ip] = now
+	}
+	w.mu.Unlock()
+
+	w.logger.Info("Parse samples uploaded", "count", len(samples))
+	return nil
+}
+
+// uploadUnknownDevices sends anonymized samples of printers the parser could
+// not attribute to a manufacturer, when the tenant opted in. Each entry is
+// sent again only after further sightings.
+func (w *UploadWorker) uploadUnknownDevices() error {
+	if w.mqtt != nil || w.client == nil || !featureflags.UnknownDeviceTelemetryEnabled() {
+		return nil
+	}
+	pending := agent.PendingUnknownDevices()
+	if len(pending) == 0 {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	if err := w.client.UploadUnknownDevices(ctx, pending); err != nil {
+		return err
+	}
+	agent.MarkUnknownDevicesReported(pending)
+	w.logger.Info("Unknown device samples uploaded", "count", len(pending))
+	return nil
+}
+
+// retryWithBackoff retries a function with exponential backoff. Failures
+// that retrying cannot fix (bad token, TLS, incompatible server) stop early.
+// The final result feeds the server circuit breaker.
 func (w *UploadWorker) retryWithBackoff(fn func() error) error {
 	var lastErr error
 
 	for attempt := 0; attempt < w.retryAttempts; attempt++ {
 		err := fn()
 		if err == nil {
+			w.recordServerResult(nil)
 			return nil // Success
 		}
 
@@ -710,9 +1133,17 @@ func (w *UploadWorker) retryWithBackoff(fn func() error) error {
 		if attempt == w.retryAttempts-1 {
 			break
 		}
+		if health := agent.ClassifyServerError(err); health != agent.ServerHealthOK && !health.Transient() {
+			break
+		}
 
 		// Exponential backoff: 2s, 4s, 8s, etc.
 		backoff := w.retryBackoff * time.Duration(1<<attempt)
+		// An overloaded server says when to come back; honor it (bounded)
+		var throttled *agent.ThrottledError
+		if errors.As(err, &throttled) && throttled.RetryAfter > backoff {
+			backoff = min(throttled.RetryAfter, maxThrottleBackoff)
+		}
 		w.logger.Debug("Retry after backoff",
 			"attempt", attempt+1,
 			"backoff", backoff,
@@ -726,19 +1157,70 @@ func (w *UploadWorker) retryWithBackoff(fn func() error) error {
 		}
 	}
 
+	w.recordServerResult(lastErr)
 	return fmt.Errorf("failed after %d attempts: %w", w.retryAttempts, lastErr)
 }
 
+// recordServerResult feeds a request result to the circuit breaker and logs
+// health changes.
+func (w *UploadWorker) recordServerResult(err error) {
+	before := w.breaker.Snapshot()
+	w.breaker.Record(err)
+	after := w.breaker.Snapshot()
+	if before.Status == after.Status && before.BreakerOpen == after.BreakerOpen &&
+		timeValue(before.NextAttempt).Equal(timeValue(after.NextAttempt)) {
+		return
+	}
+	switch {
+	case after.BreakerOpen:
+		w.logger.Warn("Server connection unhealthy, pausing uploads",
+			"status", after.Status,
+			"error", after.LastError,
+			"next_attempt", timeValue(after.NextAttempt).Format(time.RFC3339))
+	case after.Status == agent.ServerHealthOK && before.Status != agent.ServerHealthOK:
+		w.logger.Info("Server connection healthy", "previous", before.Status)
+	}
+}
+
+// RetryNow closes the circuit breaker and runs a heartbeat and upload
+// immediately instead of waiting for the paused interval.
+func (w *UploadWorker) RetryNow() {
+	if w == nil {
+		return
+	}
+	w.breaker.Reset()
+	for _, kick := range []chan struct{}{w.heartbeatKick, w.uploadKick} {
+		select {
+		case kick <- struct{}{}:
+		default:
+		}
+	}
+}
+
+func timeValue(t *time.Time) time.Time {
+	if t == nil {
+		return time.Time{}
+	}
+	return *t
+}
+
 // GetStats returns current upload worker statistics
 func (w *UploadWorker) GetStats() map[string]interface{} {
+	uploadOffset, serverSuggested := w.uploadSchedule.current()
+	heartbeatOffset, _ := w.heartbeatSchedule.current()
+
 	w.mu.RLock()
 	defer w.mu.RUnlock()
 
 	return map[string]interface{}{
-		"last_heartbeat":      w.lastHeartbeat,
-		"last_device_upload":  w.lastDeviceUpload,
-		"last_metrics_upload": w.lastMetricsUpload,
-		"heartbeat_interval":  w.heartbeatInterval.String(),
-		"upload_interval":     w.uploadInterval.String(),
+		"last_heartbeat":       w.lastHeartbeat,
+		"last_device_upload":   w.lastDeviceUpload,
+		"last_metrics_upload":  w.lastMetricsUpload,
+		"heartbeat_interval":   w.heartbeatInterval.String(),
+		"upload_interval":      w.uploadInterval.String(),
+		"heartbeat_offset":     heartbeatOffset.String(),
+		"upload_offset":        uploadOffset.String(),
+		"schedule_from_server": serverSuggested,
+		"transport":            w.transport(),
 	}
 }
@@ -1,10 +1,21 @@
 package main
 
 import (
+	"context"
+	"crypto/ed25519"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
 	"testing"
 	"time"
 
 	agentpkg "printmaster/agent/agent"
+	"printmaster/agent/featureflags"
+	"printmaster/agent/storage"
+	"printmaster/common/meterread"
 	pmsettings "printmaster/common/settings"
 )
 
@@ -44,7 +55,368 @@ func TestUploadWorkerHandleHeartbeatSettingsPersistsSnapshot(t *testing.T) {
 	}
 }
 
+func TestUploadWorkerHandleHeartbeatSettingsReappliesEnforcedSnapshot(t *testing.T) {
+	store := newFakeConfigStore()
+	mgr := NewSettingsManager(store)
+	prev := settingsManager
+	settingsManager = mgr
+	t.Cleanup(func() {
+		settingsManager = prev
+		effectiveSettingsMu.Lock()
+		effectiveSettings = nil
+		effectiveSettingsMu.Unlock()
+	})
+
+	worker := &UploadWorker{settings: mgr, logger: stubLogger{}}
+	snap := &agentpkg.SettingsSnapshot{Version: "v1", SchemaVersion: "schema-1", Settings: pmsettings.DefaultSettings()}
+	worker.handleHeartbeatSettings(&agentpkg.HeartbeatResult{Snapshot: snap})
+
+	// A local edit changes what the agent runs with but not the managed version
+	local := pmsettings.DefaultSettings()
+	local.Discovery.SNMPEnabled = !local.Discovery.SNMPEnabled
+	recordEffectiveSettings(local)
+
+	worker.handleHeartbeatSettings(&agentpkg.HeartbeatResult{Snapshot: snap, Enforce: true})
+	if store.setCount(serverManagedSettingsKey) != 2 {
+		t.Fatalf("expected enforced snapshot re-applied, got %d saves", store.setCount(serverManagedSettingsKey))
+	}
+	if got := reportedEffectiveSettings(); got == nil || got.Discovery.SNMPEnabled != snap.Settings.Discovery.SNMPEnabled {
+		t.Fatalf("expected effective settings back to policy, got %+v", got)
+	}
+}
+
 func TestUploadWorkerHandleHeartbeatSettingsIgnoresNilSnapshot(t *testing.T) {
 	worker := &UploadWorker{settings: nil, logger: stubLogger{}}
 	worker.handleHeartbeatSettings(&agentpkg.HeartbeatResult{})
 }
+
+func TestUploadWorkerRetryStopsOnPermanentFailure(t *testing.T) {
+	worker := &UploadWorker{
+		logger:        stubLogger{},
+		retryAttempts: 3,
+		retryBackoff:  time.Millisecond,
+		breaker:       agentpkg.NewCircuitBreaker(),
+		heartbeatKick: make(chan struct{}, 1),
+		uploadKick:    make(chan struct{}, 1),
+		stopCh:        make(chan struct{}),
+	}
+
+	calls := 0
+	err := worker.retryWithBackoff(func() error {
+		calls++
+		return &agentpkg.StatusError{StatusCode: http.StatusUnauthorized, Body: "invalid token"}
+	})
+	if err == nil || calls != 1 {
+		t.Fatalf("expected a single attempt for an auth failure, got %d (err %v)", calls, err)
+	}
+	health := worker.Status().Health
+	if health.Status != agentpkg.ServerHealthAuthInvalid || !health.BreakerOpen {
+		t.Fatalf("unexpected health: %+v", health)
+	}
+
+	worker.RetryNow()
+	if !worker.breaker.Allow() {
+		t.Fatal("RetryNow should close the breaker")
+	}
+	if len(worker.heartbeatKick) != 1 || len(worker.uploadKick) != 1 {
+		t.Fatal("RetryNow should wake the heartbeat and upload loops")
+	}
+}
+
+func TestUploadWorkerAdoptsRotatedToken(t *testing.T) {
+	dataDir := t.TempDir()
+	if err := SaveServerToken(dataDir, "old-token"); err != nil {
+		t.Fatalf("SaveServerToken: %v", err)
+	}
+	client := agentpkg.NewServerClient("http://127.0.0.1:0", "agent-1", "old-token")
+	worker := &UploadWorker{logger: stubLogger{}, client: client, dataDir: dataDir}
+
+	worker.adoptServerToken("new-token", time.Now().Add(time.Hour))
+	if client.GetToken() != "new-token" {
+		t.Fatalf("client should use the rotated token, got %q", client.GetToken())
+	}
+	if got := LoadServerToken(dataDir); got != "new-token" {
+		t.Fatalf("rotated token not persisted, got %q", got)
+	}
+	entries, _ := os.ReadDir(dataDir)
+	if len(entries) != 1 {
+		t.Fatalf("temporary token files left behind: %v", entries)
+	}
+
+	// A token that cannot be persisted is not adopted
+	worker.dataDir = filepath.Join(dataDir, "agent_token", "nested")
+	worker.adoptServerToken("unsaved-token", time.Time{})
+	if client.GetToken() != "new-token" {
+		t.Fatalf("unpersisted token should not be adopted")
+	}
+}
+
+type fakeMQTTTransport struct {
+	mu        sync.Mutex
+	connected bool
+	published map[string]map[string]interface{}
+}
+
+func (f *fakeMQTTTransport) Start() error      { return nil }
+func (f *fakeMQTTTransport) Stop()             {}
+func (f *fakeMQTTTransport) IsConnected() bool { return f.connected }
+func (f *fakeMQTTTransport) Broker() string    { return "tcp://broker:1883" }
+
+func (f *fakeMQTTTransport) Publish(_ context.Context, kind string, payload interface{}) error {
+	if !f.connected {
+		return agentpkg.ErrMQTTNotConnected
+	}
+	data, _ := json.Marshal(payload)
+	var decoded map[string]interface{}
+	_ = json.Unmarshal(data, &decoded)
+	f.mu.Lock()
+	f.published[kind] = decoded
+	f.mu.Unlock()
+	return nil
+}
+
+func (f *fakeMQTTTransport) PublishBatch(ctx context.Context, kind, field string, items []interface{}) error {
+	return f.Publish(ctx, kind, map[string]interface{}{"agent_id": "agent-1", field: items})
+}
+
+func TestUploadWorkerPublishesOverMQTT(t *testing.T) {
+	store, err := storage.NewSQLiteStore(":memory:")
+	if err != nil {
+		t.Fatalf("NewSQLiteStore: %v", err)
+	}
+	defer store.Close()
+	ctx := context.Background()
+
+	device := &storage.Device{}
+	device.Serial = "SN1"
+	device.IP = "10.0.0.5"
+	device.Visible = true
+	device.IsSaved = true
+	if err := store.Create(ctx, device); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	read := &storage.MeterRead{ID: "mr-1", Serial: "SN1", AgentID: "agent-1", Period: "2026-09",
+		Trigger: meterread.TriggerScheduled, ReadAt: time.Now().UTC(), PageCount: 100}
+	if err := store.AddMeterRead(ctx, read); err != nil {
+		t.Fatalf("AddMeterRead: %v", err)
+	}
+
+	transport := &fakeMQTTTransport{published: map[string]map[string]interface{}{}}
+	worker := NewUploadWorker(agentpkg.NewServerClient("", "agent-1", ""), store, stubLogger{}, nil,
+		UploadWorkerConfig{RetryAttempts: 1, UseWebSocket: true, MQTT: transport}, t.TempDir())
+	if worker.useWebSocket {
+		t.Fatal("WebSocket should be disabled with the MQTT transport")
+	}
+
+	// Nothing is marked uploaded while the broker is unreachable
+	worker.doUpload()
+	if pending, _ := store.ListPendingMeterReads(ctx, 10); len(pending) != 1 {
+		t.Fatalf("expected the meter read to stay pending, got %d", len(pending))
+	}
+
+	worker.breaker.Reset()
+	transport.connected = true
+	worker.doUpload()
+	worker.sendHeartbeat()
+
+	if devices, _ := transport.published[agentpkg.MQTTTopicDevices]["devices"].([]interface{}); len(devices) != 1 {
+		t.Fatalf("expected one device published, got %v", transport.published[agentpkg.MQTTTopicDevices])
+	}
+	if reads, _ := transport.published[agentpkg.MQTTTopicMeterReads]["reads"].([]interface{}); len(reads) != 1 {
+		t.Fatalf("expected one meter read published, got %v", transport.published[agentpkg.MQTTTopicMeterReads])
+	}
+	if pending, _ := store.ListPendingMeterReads(ctx, 10); len(pending) != 0 {
+		t.Fatalf("acknowledged meter read still pending")
+	}
+	if hb := transport.published[agentpkg.MQTTTopicHeartbeat]; hb["agent_id"] != "agent-1" || hb["device_count"] != float64(1) {
+		t.Fatalf("unexpected heartbeat %v", hb)
+	}
+	status := worker.Status()
+	if status.Transport != "mqtt" || !status.MQTTConnected || status.LastHeartbeat.IsZero() {
+		t.Fatalf("unexpected status %+v", status)
+	}
+}
+
+func TestUploadMeterReadsQuarantinesSignatureRejections(t *testing.T) {
+	store, err := storage.NewSQLiteStore(":memory:")
+	if err != nil {
+		t.Fatalf("NewSQLiteStore: %v", err)
+	}
+	defer store.Close()
+	ctx := context.Background()
+
+	// Both reads are signed with the agent's key; one is altered afterwards
+	pub, key, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, id := range []string{"mr-queued", "mr-tampered"} {
+		read := &storage.MeterRead{ID: id, Serial: "SN1", AgentID: "agent-1", Period: "2026-09",
+			Trigger: meterread.TriggerScheduled, ReadAt: time.Now().UTC(), PageCount: 100}
+		read.Sign(key)
+		if id == "mr-tampered" {
+			read.PageCount++
+		}
+		if err := store.AddMeterRead(ctx, read); err != nil {
+			t.Fatalf("AddMeterRead: %v", err)
+		}
+	}
+
+	// The server verifies against the agent's registered key, whatever token
+	// the upload is made with
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			Reads []meterread.Read `json:"reads"`
+		}
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		resp := map[string]interface{}{"accepted": []string{}, "rejected": []map[string]string{}}
+		for _, read := range body.Reads {
+			if err := read.Verify(pub); err != nil {
+				resp["rejected"] = append(resp["rejected"].([]map[string]string), map[string]string{"id": read.ID, "reason": err.Error()})
+			} else {
+				resp["accepted"] = append(resp["accepted"].([]string), read.ID)
+			}
+		}
+		json.NewEncoder(w).Encode(resp)
+	}))
+	defer srv.Close()
+
+	client := agentpkg.NewServerClient(srv.URL, "agent-1", "token-old")
+	worker := NewUploadWorker(client, store, stubLogger{}, nil, UploadWorkerConfig{RetryAttempts: 1}, t.TempDir())
+	// Reads queued before a token rotation are unaffected by it
+	client.SetToken("token-new")
+
+	// The tampered read is refused: quarantined, not uploaded
+	if err := worker.uploadMeterReads(); err != nil {
+		t.Fatalf("uploadMeterReads: %v", err)
+	}
+	if pending, _ := store.ListPendingMeterReads(ctx, 10); len(pending) != 0 {
+		t.Fatalf("reads still pending: %+v", pending)
+	}
+	reads, _ := store.ListMeterReads(ctx, "SN1", 0)
+	for _, read := range reads {
+		switch read.ID {
+		case "mr-queued":
+			if read.UploadedAt.IsZero() || !read.QuarantinedAt.IsZero() {
+				t.Errorf("accepted read = %+v", read)
+			}
+		case "mr-tampered":
+			if !read.UploadedAt.IsZero() || read.QuarantinedAt.IsZero() || read.QuarantineReason != meterread.ErrBadSignature.Error() {
+				t.Errorf("rejected read = %+v", read)
+			}
+		}
+	}
+}
+
+func TestUploadWorkerSendsParseSamplesForGaps(t *testing.T) {
+	store, err := storage.NewSQLiteStore(":memory:")
+	if err != nil {
+		t.Fatalf("NewSQLiteStore: %v", err)
+	}
+	defer store.Close()
+	ctx := context.Background()
+
+	for _, d := range []struct{ serial, ip, model string }{
+		{"10.9.0.5", "10.9.0.5", ""},       // serial-less, no model
+		{"CNB1234567", "10.9.0.6", "M404"}, // no metrics yet
+	} {
+		device := &storage.Device{Visible: true}
+		device.Serial, device.IP, device.Manufacturer, device.Model = d.serial, d.ip, "HP", d.model
+		device.RawData = map[string]interface{}{"sys_object_id": "1.3.6.1.4.1.11.2.3.9.1"}
+		if err := store.Create(ctx, device); err != nil {
+			t.Fatalf("Create: %v", err)
+		}
+	}
+	prevDump := agentpkg.DumpParseDebugEnabled
+	agentpkg.SetDumpParseDebug(false)
+	t.Cleanup(func() { agentpkg.SetDumpParseDebug(prevDump) })
+	agentpkg.RecordParseDebug("10.9.0.5", agentpkg.ParseDebug{IP: "10.9.0.5", Steps: []string{"no serial OID"},
+		RawPDUs: []agentpkg.RawPDU{{OID: "1.3.6.1.2.1.1.2.0", Type: "ObjectIdentifier", StrValue: ".1.3.6.1.4.1.11.2.3.9.1"}}})
+
+	var mu sync.Mutex
+	var received []map[string]interface{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v1/parse-samples/batch" {
+			http.NotFound(w, r)
+			return
+		}
+		var body struct {
+			Samples []map[string]interface{} `json:"samples"`
+		}
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		mu.Lock()
+		received = append(received, body.Samples...)
+		mu.Unlock()
+		w.Write([]byte(`{"stored":1}`))
+	}))
+	defer srv.Close()
+
+	worker := NewUploadWorker(agentpkg.NewServerClient(srv.URL, "agent-1", "token"), store, stubLogger{}, nil,
+		UploadWorkerConfig{RetryAttempts: 1}, t.TempDir())
+	if err := worker.uploadParseSamples(); err != nil {
+		t.Fatalf("uploadParseSamples: %v", err)
+	}
+	// Already sent within the interval
+	if err := worker.uploadParseSamples(); err != nil {
+		t.Fatalf("uploadParseSamples: %v", err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	// Only the device with a parse-debug snapshot is sent, once
+	if len(received) != 1 || received[0]["ip"] != "10.9.0.5" {
+		t.Fatalf("unexpected samples %v", received)
+	}
+	missing, _ := received[0]["missing"].([]interface{})
+	if len(missing) != 4 || received[0]["sys_object_id"] != "1.3.6.1.4.1.11.2.3.9.1" {
+		t.Fatalf("unexpected sample %v", received[0])
+	}
+}
+
+func TestUploadWorkerSendsUnknownDevicesWhenOptedIn(t *testing.T) {
+	agentpkg.ResetUnknownDevices()
+	t.Cleanup(agentpkg.ResetUnknownDevices)
+	t.Cleanup(func() { featureflags.SetUnknownDeviceTelemetry(false) })
+	t.Chdir(t.TempDir()) // unknown_mfg.log is written under ./logs
+	agentpkg.RecordUnknownDevice("10.9.0.7", "ZX998877", "1.3.6.1.4.1.55555.1", "Acme MFP ZX998877", "MFP 3000")
+
+	var mu sync.Mutex
+	var received []map[string]interface{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			Devices []map[string]interface{} `json:"devices"`
+		}
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		mu.Lock()
+		received = append(received, body.Devices...)
+		mu.Unlock()
+		w.Write([]byte(`{"stored":1}`))
+	}))
+	defer srv.Close()
+
+	worker := NewUploadWorker(agentpkg.NewServerClient(srv.URL, "agent-1", "token"), nil, stubLogger{}, nil,
+		UploadWorkerConfig{RetryAttempts: 1}, t.TempDir())
+
+	// Off by default
+	if err := worker.uploadUnknownDevices(); err != nil {
+		t.Fatalf("uploadUnknownDevices: %v", err)
+	}
+	featureflags.SetUnknownDeviceTelemetry(true)
+	for i := 0; i < 2; i++ {
+		if err := worker.uploadUnknownDevices(); err != nil {
+			t.Fatalf("uploadUnknownDevices: %v", err)
+		}
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(received) != 1 {
+		t.Fatalf("expected one upload, got %v", received)
+	}
+	if received[0]["sys_descr"] != "Acme MFP <serial>" || received[0]["enterprise"] != "55555" {
+		t.Fatalf("unexpected sample %v", received[0])
+	}
+	if _, ok := received[0]["ip"]; ok {
+		t.Fatalf("sample must not carry the IP: %v", received[0])
+	}
+}
@@ -0,0 +1,95 @@
+package watchdog
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"runtime/pprof"
+	"sort"
+	"strings"
+	"time"
+)
+
+// MaxCrashBundles is the number of crash bundles kept; older ones are removed.
+const MaxCrashBundles = 10
+
+// Report is the summary written to report.json in a crash bundle.
+type Report struct {
+	Time     time.Time         `json:"time"`
+	Reason   string            `json:"reason"`
+	PID      int               `json:"pid"`
+	OS       string            `json:"os"`
+	Arch     string            `json:"arch"`
+	Stalls   []Stall           `json:"stalls,omitempty"`
+	ExitCode *int              `json:"exit_code,omitempty"`
+	Info     map[string]string `json:"info,omitempty"`
+}
+
+// WriteCrashBundle writes a crash bundle directory under dir containing
+// report.json, a goroutine dump of the current process (when goroutines is
+// set) and output captured from a worker process (when non-empty). It
+// returns the bundle directory.
+func WriteCrashBundle(dir string, report Report, goroutines bool, output []byte) (string, error) {
+	if report.Time.IsZero() {
+		report.Time = time.Now().UTC()
+	}
+	if report.PID == 0 {
+		report.PID = os.Getpid()
+	}
+	report.OS = runtime.GOOS
+	report.Arch = runtime.GOARCH
+
+	bundle := filepath.Join(dir, "crash-"+report.Time.UTC().Format("20060102T150405.000Z"))
+	if err := os.MkdirAll(bundle, 0755); err != nil {
+		return "", fmt.Errorf("create crash bundle: %w", err)
+	}
+
+	b, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	if err := os.WriteFile(filepath.Join(bundle, "report.json"), b, 0644); err != nil {
+		return "", err
+	}
+	if goroutines {
+		var buf bytes.Buffer
+		if err := pprof.Lookup("goroutine").WriteTo(&buf, 2); err == nil {
+			if err := os.WriteFile(filepath.Join(bundle, "goroutines.txt"), buf.Bytes(), 0644); err != nil {
+				return bundle, err
+			}
+		}
+	}
+	if len(output) > 0 {
+		if err := os.WriteFile(filepath.Join(bundle, "worker-output.txt"), output, 0644); err != nil {
+			return bundle, err
+		}
+	}
+
+	pruneCrashBundles(dir, MaxCrashBundles)
+	return bundle, nil
+}
+
+// pruneCrashBundles removes all but the newest keep bundles in dir.
+func pruneCrashBundles(dir string, keep int) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return
+	}
+	var bundles []string
+	for _, e := range entries {
+		if e.IsDir() && strings.HasPrefix(e.Name(), "crash-") {
+			bundles = append(bundles, e.Name())
+		}
+	}
+	if len(bundles) <= keep {
+		return
+	}
+	// Names embed a sortable UTC timestamp
+	sort.Strings(bundles)
+	for _, name := range bundles[:len(bundles)-keep] {
+		os.RemoveAll(filepath.Join(dir, name))
+	}
+}
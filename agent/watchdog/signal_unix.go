@@ -0,0 +1,18 @@
+//go:build !windows
+
+package watchdog
+
+import (
+	"os"
+	"syscall"
+)
+
+// terminate asks the worker to shut down gracefully.
+func terminate(p *os.Process) {
+	p.Signal(syscall.SIGTERM)
+}
+
+// dumpStacks makes a Go worker print all goroutine stacks to stderr and exit.
+func dumpStacks(p *os.Process) {
+	p.Signal(syscall.SIGQUIT)
+}
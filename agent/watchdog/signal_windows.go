@@ -0,0 +1,14 @@
+//go:build windows
+
+package watchdog
+
+import "os"
+
+// terminate stops the worker. Windows has no signal a console-less child can
+// handle, so the worker is killed outright.
+func terminate(p *os.Process) {
+	p.Kill()
+}
+
+// dumpStacks is not supported on Windows; the caller kills the worker.
+func dumpStacks(p *os.Process) {}
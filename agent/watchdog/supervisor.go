@@ -0,0 +1,188 @@
+package watchdog
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"sync"
+	"time"
+)
+
+// Supervisor runs the agent as a worker process and restarts it when it
+// exits or its heartbeat file goes stale.
+type Supervisor struct {
+	// Command builds the worker command; it is called for every start. The
+	// supervisor adds EnvChild and EnvHeartbeatFile to its environment.
+	Command       func() *exec.Cmd
+	HeartbeatFile string
+	StallTimeout  time.Duration // Heartbeat age at which the worker is considered hung (default 3m)
+	StartupGrace  time.Duration // Time allowed before the first heartbeat (default 3m)
+	CheckInterval time.Duration // How often the heartbeat is checked (default 10s)
+	RestartDelay  time.Duration // Delay before restarting; doubles on crash loops up to 1m (default 5s)
+	StopTimeout   time.Duration // Time allowed for a graceful stop before killing (default 25s)
+	CrashDir      string        // Where crash bundles are written; empty disables
+	Info          map[string]string
+	Logf          func(format string, args ...interface{})
+}
+
+// outputTailSize is how much recent worker output is kept for crash bundles.
+const outputTailSize = 256 * 1024
+
+// Run supervises the worker until ctx is done, then stops it gracefully.
+func (s *Supervisor) Run(ctx context.Context) error {
+	if s.Command == nil || s.HeartbeatFile == "" {
+		return errors.New("watchdog: supervisor needs a command and heartbeat file")
+	}
+	stallTimeout := orDefault(s.StallTimeout, 3*time.Minute)
+	startupGrace := orDefault(s.StartupGrace, 3*time.Minute)
+	checkInterval := orDefault(s.CheckInterval, 10*time.Second)
+	baseDelay := orDefault(s.RestartDelay, 5*time.Second)
+	stopTimeout := orDefault(s.StopTimeout, 25*time.Second)
+
+	delay := baseDelay
+	for {
+		os.Remove(s.HeartbeatFile)
+		output := &tailBuffer{max: outputTailSize}
+		cmd := s.Command()
+		if cmd.Env == nil {
+			cmd.Env = os.Environ()
+		}
+		cmd.Env = append(cmd.Env, EnvChild+"=1", EnvHeartbeatFile+"="+s.HeartbeatFile)
+		cmd.Stdout = output
+		cmd.Stderr = output
+		if err := cmd.Start(); err != nil {
+			return fmt.Errorf("watchdog: start worker: %w", err)
+		}
+		started := time.Now()
+		s.logf("watchdog: worker started (pid %d)", cmd.Process.Pid)
+
+		exited := make(chan error, 1)
+		go func() { exited <- cmd.Wait() }()
+
+		reason, stopped, exitErr := s.watch(ctx, cmd, exited, started, stallTimeout, startupGrace, checkInterval, stopTimeout)
+		if stopped {
+			s.logf("watchdog: worker stopped")
+			return nil
+		}
+
+		code := exitCode(exitErr)
+		report := Report{Reason: reason, Info: s.Info}
+		if code >= 0 {
+			report.ExitCode = &code
+		}
+		// A worker exiting with ExitStalled already wrote its own bundle
+		if s.CrashDir != "" && code != 0 && code != ExitStalled {
+			if bundle, err := WriteCrashBundle(s.CrashDir, report, false, output.Bytes()); err != nil {
+				s.logf("watchdog: failed to write crash bundle: %v", err)
+			} else {
+				s.logf("watchdog: crash bundle written to %s", bundle)
+			}
+		}
+
+		// Back off when the worker keeps dying right after start
+		if time.Since(started) < time.Minute {
+			delay = min(delay*2, time.Minute)
+		} else {
+			delay = baseDelay
+		}
+		s.logf("watchdog: %s; restarting in %s", reason, delay)
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(delay):
+		}
+	}
+}
+
+// watch waits for the worker to exit, hang or be stopped via ctx. It returns
+// why the worker ended, whether it was stopped on request and its exit error.
+func (s *Supervisor) watch(ctx context.Context, cmd *exec.Cmd, exited <-chan error, started time.Time,
+	stallTimeout, startupGrace, checkInterval, stopTimeout time.Duration) (reason string, stopped bool, exitErr error) {
+	ticker := time.NewTicker(checkInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case err := <-exited:
+			return fmt.Sprintf("worker exited (code %d)", exitCode(err)), false, err
+		case <-ctx.Done():
+			terminate(cmd.Process)
+			select {
+			case <-exited:
+			case <-time.After(stopTimeout):
+				cmd.Process.Kill()
+				<-exited
+			}
+			return "", true, nil
+		case <-ticker.C:
+			deadline := started.Add(startupGrace)
+			if hb, err := ReadHeartbeat(s.HeartbeatFile); err == nil {
+				deadline = hb.Add(stallTimeout)
+			}
+			if time.Now().Before(deadline) {
+				continue
+			}
+			s.logf("watchdog: worker heartbeat stale, restarting (pid %d)", cmd.Process.Pid)
+			// Ask for a goroutine dump first where the platform supports it
+			dumpStacks(cmd.Process)
+			select {
+			case err := <-exited:
+				return "worker hung (heartbeat stale)", false, err
+			case <-time.After(5 * time.Second):
+			}
+			cmd.Process.Kill()
+			return "worker hung (heartbeat stale)", false, <-exited
+		}
+	}
+}
+
+func (s *Supervisor) logf(format string, args ...interface{}) {
+	if s.Logf != nil {
+		s.Logf(format, args...)
+	}
+}
+
+func orDefault(d, def time.Duration) time.Duration {
+	if d <= 0 {
+		return def
+	}
+	return d
+}
+
+// exitCode returns the process exit code for a Wait error, 0 for nil and -1
+// when the code is unknown.
+func exitCode(err error) int {
+	if err == nil {
+		return 0
+	}
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		return exitErr.ExitCode()
+	}
+	return -1
+}
+
+// tailBuffer keeps the last max bytes written to it.
+type tailBuffer struct {
+	mu  sync.Mutex
+	max int
+	buf []byte
+}
+
+func (t *tailBuffer) Write(p []byte) (int, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.buf = append(t.buf, p...)
+	if len(t.buf) > t.max {
+		t.buf = append([]byte(nil), t.buf[len(t.buf)-t.max:]...)
+	}
+	return len(p), nil
+}
+
+// Bytes returns a copy of the buffered output.
+func (t *tailBuffer) Bytes() []byte {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return append([]byte(nil), t.buf...)
+}
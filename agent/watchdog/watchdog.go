@@ -0,0 +1,196 @@
+// Package watchdog detects a hung agent and gets it restarted. Long-running
+// loops wrap each unit of work in Monitor.Begin; a supervisory goroutine
+// (Watchdog.Run) reports any unit that overruns its deadline, writes a crash
+// bundle and refreshes a heartbeat file while everything is healthy. In
+// child-process mode a Supervisor runs the agent as a worker process, watches
+// that heartbeat file and restarts the worker when it goes stale or exits.
+package watchdog
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ExitStalled is the exit code used by a worker that stopped itself after
+// detecting a stall, so supervisors and service managers restart it.
+const ExitStalled = 70
+
+// Environment variables passed from a Supervisor to its worker process.
+const (
+	EnvChild         = "PRINTMASTER_WATCHDOG_CHILD"
+	EnvHeartbeatFile = "PRINTMASTER_WATCHDOG_HEARTBEAT"
+)
+
+// Stall describes a unit of work that overran its deadline.
+type Stall struct {
+	Name    string        `json:"name"`
+	Started time.Time     `json:"started"`
+	Timeout time.Duration `json:"timeout"`
+	Running time.Duration `json:"running"`
+}
+
+func (s Stall) String() string {
+	return fmt.Sprintf("%s running for %s (timeout %s)", s.Name, s.Running.Round(time.Second), s.Timeout)
+}
+
+type unit struct {
+	name    string
+	started time.Time
+	timeout time.Duration
+}
+
+// Monitor tracks in-flight units of work. The zero value is not usable; use
+// NewMonitor. A nil *Monitor is a no-op so callers need not check whether the
+// watchdog is enabled.
+type Monitor struct {
+	mu     sync.Mutex
+	nextID uint64
+	units  map[uint64]unit
+	now    func() time.Time
+}
+
+// NewMonitor creates an empty monitor.
+func NewMonitor() *Monitor {
+	return &Monitor{units: make(map[uint64]unit), now: time.Now}
+}
+
+// Begin marks the start of a unit of work on the named loop that must finish
+// within timeout. The returned function marks it done and is safe to call
+// more than once.
+func (m *Monitor) Begin(name string, timeout time.Duration) (done func()) {
+	if m == nil || timeout <= 0 {
+		return func() {}
+	}
+	m.mu.Lock()
+	m.nextID++
+	id := m.nextID
+	m.units[id] = unit{name: name, started: m.now(), timeout: timeout}
+	m.mu.Unlock()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			m.mu.Lock()
+			delete(m.units, id)
+			m.mu.Unlock()
+		})
+	}
+}
+
+// Stalled returns the units that have run past their timeout, longest
+// running first.
+func (m *Monitor) Stalled() []Stall {
+	if m == nil {
+		return nil
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := m.now()
+	var stalls []Stall
+	for _, u := range m.units {
+		if running := now.Sub(u.started); running > u.timeout {
+			stalls = append(stalls, Stall{Name: u.name, Started: u.started, Timeout: u.timeout, Running: running})
+		}
+	}
+	sort.Slice(stalls, func(i, j int) bool { return stalls[i].Running > stalls[j].Running })
+	return stalls
+}
+
+// Config controls the supervisory goroutine.
+type Config struct {
+	Monitor       *Monitor
+	CheckInterval time.Duration // How often to check for stalls (default 30s)
+	HeartbeatFile string        // Refreshed after every healthy check; empty disables
+	CrashDir      string        // Where crash bundles are written; empty disables
+	Info          map[string]string
+	// OnStall is called after the crash bundle has been written. bundle is
+	// the bundle directory ("" if none was written).
+	OnStall func(stalls []Stall, bundle string)
+	Logf    func(format string, args ...interface{})
+}
+
+// Run checks the monitor until ctx is done. Once a stall is reported the
+// heartbeat file is no longer refreshed, so a supervising process restarts
+// the worker even if OnStall returns.
+func Run(ctx context.Context, cfg Config) {
+	interval := cfg.CheckInterval
+	if interval <= 0 {
+		interval = 30 * time.Second
+	}
+	logf := cfg.Logf
+	if logf == nil {
+		logf = func(string, ...interface{}) {}
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if cfg.HeartbeatFile != "" {
+			if err := WriteHeartbeat(cfg.HeartbeatFile, time.Now()); err != nil {
+				logf("watchdog: failed to write heartbeat: %v", err)
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		stalls := cfg.Monitor.Stalled()
+		if len(stalls) == 0 {
+			continue
+		}
+		bundle := ""
+		if cfg.CrashDir != "" {
+			details := make([]string, len(stalls))
+			for i, s := range stalls {
+				details[i] = s.String()
+			}
+			var err error
+			bundle, err = WriteCrashBundle(cfg.CrashDir, Report{
+				Reason: "stalled: " + strings.Join(details, "; "),
+				Stalls: stalls,
+				Info:   cfg.Info,
+			}, true, nil)
+			if err != nil {
+				logf("watchdog: failed to write crash bundle: %v", err)
+			}
+		}
+		if cfg.OnStall != nil {
+			cfg.OnStall(stalls, bundle)
+		}
+		return
+	}
+}
+
+// WriteHeartbeat atomically records t in the heartbeat file.
+func WriteHeartbeat(path string, t time.Time) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, []byte(strconv.FormatInt(t.UnixNano(), 10)), 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// ReadHeartbeat returns the time recorded in the heartbeat file.
+func ReadHeartbeat(path string) (time.Time, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return time.Time{}, err
+	}
+	n, err := strconv.ParseInt(strings.TrimSpace(string(b)), 10, 64)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid heartbeat file: %w", err)
+	}
+	return time.Unix(0, n), nil
+}
@@ -0,0 +1,151 @@
+package watchdog
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// TestMain lets the test binary act as a supervised worker.
+func TestMain(m *testing.M) {
+	switch os.Getenv("WATCHDOG_TEST_WORKER") {
+	case "hang":
+		// Never writes a heartbeat
+		time.Sleep(time.Minute)
+		os.Exit(0)
+	case "healthy":
+		for {
+			WriteHeartbeat(os.Getenv(EnvHeartbeatFile), time.Now())
+			time.Sleep(20 * time.Millisecond)
+		}
+	}
+	os.Exit(m.Run())
+}
+
+func TestMonitorStalled(t *testing.T) {
+	t.Parallel()
+	m := NewMonitor()
+	now := time.Now()
+	m.now = func() time.Time { return now }
+
+	doneFast := m.Begin("fast", time.Minute)
+	doneSlow := m.Begin("metrics", time.Minute)
+	now = now.Add(30 * time.Second)
+	doneFast()
+	if stalls := m.Stalled(); len(stalls) != 0 {
+		t.Fatalf("Stalled() = %v, want none", stalls)
+	}
+
+	now = now.Add(time.Minute)
+	stalls := m.Stalled()
+	if len(stalls) != 1 || stalls[0].Name != "metrics" {
+		t.Fatalf("Stalled() = %v, want metrics", stalls)
+	}
+	doneSlow()
+	doneSlow()
+	if stalls := m.Stalled(); len(stalls) != 0 {
+		t.Fatalf("Stalled() after done = %v, want none", stalls)
+	}
+
+	var nilMonitor *Monitor
+	nilMonitor.Begin("x", time.Second)()
+	if nilMonitor.Stalled() != nil {
+		t.Fatal("nil monitor reported stalls")
+	}
+}
+
+func TestRunWritesCrashBundleOnStall(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+	m := NewMonitor()
+	m.Begin("snmp_walk", time.Millisecond)
+
+	stalled := make(chan string, 1)
+	Run(context.Background(), Config{
+		Monitor:       m,
+		CheckInterval: 10 * time.Millisecond,
+		HeartbeatFile: filepath.Join(dir, "heartbeat"),
+		CrashDir:      filepath.Join(dir, "crash"),
+		OnStall: func(stalls []Stall, bundle string) {
+			stalled <- bundle
+		},
+	})
+
+	bundle := <-stalled
+	if bundle == "" {
+		t.Fatal("no crash bundle written")
+	}
+	for _, name := range []string{"report.json", "goroutines.txt"} {
+		if _, err := os.Stat(filepath.Join(bundle, name)); err != nil {
+			t.Errorf("bundle missing %s: %v", name, err)
+		}
+	}
+	if _, err := ReadHeartbeat(filepath.Join(dir, "heartbeat")); err != nil {
+		t.Errorf("heartbeat not written: %v", err)
+	}
+}
+
+func TestPruneCrashBundles(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+	for _, name := range []string{"crash-20260101T000000.000Z", "crash-20260102T000000.000Z", "crash-20260103T000000.000Z"} {
+		os.MkdirAll(filepath.Join(dir, name), 0755)
+	}
+	pruneCrashBundles(dir, 2)
+	if _, err := os.Stat(filepath.Join(dir, "crash-20260101T000000.000Z")); !os.IsNotExist(err) {
+		t.Error("oldest bundle not pruned")
+	}
+	if _, err := os.Stat(filepath.Join(dir, "crash-20260103T000000.000Z")); err != nil {
+		t.Error("newest bundle pruned")
+	}
+}
+
+func TestSupervisorRestartsHungWorker(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+	var starts atomic.Int32
+	ctx, cancel := context.WithCancel(context.Background())
+
+	s := &Supervisor{
+		Command: func() *exec.Cmd {
+			mode := "hang"
+			if starts.Add(1) > 1 {
+				mode = "healthy"
+			}
+			cmd := exec.Command(os.Args[0], "-test.run=^$")
+			cmd.Env = append(os.Environ(), "WATCHDOG_TEST_WORKER="+mode)
+			return cmd
+		},
+		HeartbeatFile: filepath.Join(dir, "heartbeat"),
+		StallTimeout:  500 * time.Millisecond,
+		StartupGrace:  500 * time.Millisecond,
+		CheckInterval: 20 * time.Millisecond,
+		RestartDelay:  10 * time.Millisecond,
+		StopTimeout:   time.Second,
+		CrashDir:      filepath.Join(dir, "crash"),
+	}
+	errc := make(chan error, 1)
+	go func() { errc <- s.Run(ctx) }()
+
+	// The hung worker is replaced by a healthy one that keeps running
+	deadline := time.Now().Add(15 * time.Second)
+	for starts.Load() < 2 && time.Now().Before(deadline) {
+		time.Sleep(20 * time.Millisecond)
+	}
+	time.Sleep(time.Second)
+	cancel()
+	if err := <-errc; err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if n := starts.Load(); n != 2 {
+		t.Fatalf("worker started %d times, want 2", n)
+	}
+	bundles, _ := filepath.Glob(filepath.Join(dir, "crash", "crash-*", "report.json"))
+	if len(bundles) != 1 {
+		t.Fatalf("crash bundles = %v, want 1", bundles)
+	}
+}
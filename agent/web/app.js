@@ -9,6 +9,10 @@ const AGENT_UI_STATE_KEYS = {
     ACTIVE_TAB: 'pm_agent_active_tab',
 };
 
+// The agent serves QR asset tags (/api/devices/qr); show them in device details
+window.__pm_shared_cards = window.__pm_shared_cards || {};
+window.__pm_shared_cards.assetTagsEnabled = true;
+
 // Toggle SNMPv3 settings visibility based on SNMP version selection
 function toggleSNMPv3Settings() {
     const version = document.getElementById('dev_snmp_version')?.value || '2c';
@@ -1582,6 +1586,221 @@ async function clearDatabase() {
     }
 }
 
+// ===== SNMP OID Browser =====
+
+const OID_LEARN_FIELDS = ['page_count', 'mono_pages', 'color_pages', 'cyan', 'magenta', 'yellow', 'serial', 'model'];
+
+async function runOIDBrowserQuery() {
+    const target = document.getElementById('oid_browser_target').value.trim();
+    const mode = document.getElementById('oid_browser_mode').value;
+    const oids = document.getElementById('oid_browser_oids').value.split(/[\s,]+/).filter(Boolean);
+    const maxEntries = parseInt(document.getElementById('oid_browser_max').value, 10) || 500;
+    const status = document.getElementById('oid_browser_status');
+    if (!target || oids.length === 0) {
+        status.textContent = 'Enter a device serial or IP and at least one OID.';
+        return;
+    }
+
+    const isIP = /^[0-9.]+$/.test(target) || target.includes(':');
+    const body = { mode, oids, max_entries: maxEntries };
+    if (isIP) body.ip = target; else body.serial = target;
+
+    status.textContent = mode === 'walk' ? 'Walking...' : 'Querying...';
+    try {
+        const r = await fetch('/api/snmp/query', {
+            method: 'POST',
+            headers: { 'Content-Type': 'application/json' },
+            body: JSON.stringify(body)
+        });
+        if (!r.ok) throw new Error(await r.text());
+        const result = await r.json();
+        status.textContent = result.error
+            ? 'Error: ' + result.error
+            : `${result.count} result(s) from ${result.ip} in ${result.duration_ms} ms${result.truncated ? ' (truncated)' : ''}`;
+        renderOIDBrowserResults(result, isIP ? '' : target);
+        loadOIDBrowserHistory();
+    } catch (e) {
+        status.textContent = 'Query failed: ' + e.message;
+    }
+}
+
+function renderOIDBrowserResults(result, serial) {
+    const container = document.getElementById('oid_browser_results');
+    const rows = result.results || [];
+    if (rows.length === 0) {
+        container.innerHTML = '';
+        return;
+    }
+    const fieldOptions = OID_LEARN_FIELDS.map(f => `<option value="${f}">${f}</option>`).join('');
+    let html = '<table class="simple-table" style="width:100%;font-size:12px;"><thead><tr><th>OID</th><th>Type</th><th>Value</th>';
+    if (serial) html += '<th>Use as learned OID</th>';
+    html += '</tr></thead><tbody>';
+    rows.forEach((row, i) => {
+        html += '<tr><td style="font-family:monospace">' + escapeHtml(row.oid) + '</td>';
+        html += '<td>' + escapeHtml(row.type) + '</td>';
+        html += '<td style="font-family:monospace;word-break:break-all">' + escapeHtml(row.value) + '</td>';
+        if (serial) {
+            html += `<td><select id="oid_learn_field_${i}">${fieldOptions}</select> ` +
+                `<button class="oid-learn-btn" data-index="${i}">Use</button></td>`;
+        }
+        html += '</tr>';
+    });
+    html += '</tbody></table>';
+    container.innerHTML = html;
+
+    container.querySelectorAll('.oid-learn-btn').forEach(btn => {
+        btn.addEventListener('click', () => {
+            const i = btn.dataset.index;
+            const field = document.getElementById('oid_learn_field_' + i).value;
+            learnOIDFromBrowser(serial, field, rows[i].oid);
+        });
+    });
+}
+
+async function learnOIDFromBrowser(serial, field, oid) {
+    try {
+        const r = await fetch('/api/snmp/learn', {
+            method: 'POST',
+            headers: { 'Content-Type': 'application/json' },
+            body: JSON.stringify({ serial, field, oid })
+        });
+        if (!r.ok) throw new Error(await r.text());
+        window.__pm_shared.showToast(`Saved ${oid} as ${field} for ${serial}`, 'success');
+    } catch (e) {
+        window.__pm_shared.showToast('Failed to save learned OID: ' + e.message, 'error');
+    }
+}
+
+async function loadOIDBrowserHistory() {
+    const container = document.getElementById('oid_browser_history');
+    if (!container) return;
+    try {
+        const r = await fetch('/api/snmp/history');
+        if (!r.ok) return;
+        const data = await r.json();
+        const history = data.history || [];
+        if (history.length === 0) {
+            container.innerHTML = '<div class="muted-text">No queries yet</div>';
+            return;
+        }
+        container.innerHTML = history.map((q, i) => `
+            <div class="oid-history-item" data-index="${i}" style="cursor:pointer;padding:4px 0;border-bottom:1px solid var(--border);">
+                <span style="color:var(--muted)">${new Date(q.at).toLocaleTimeString()}</span>
+                <strong>${escapeHtml(q.mode)}</strong> ${escapeHtml(q.serial || q.ip)}
+                <span style="font-family:monospace">${escapeHtml((q.oids || []).join(' '))}</span>
+                &mdash; ${q.error ? 'error: ' + escapeHtml(q.error) : q.count + ' result(s)'}
+            </div>
+        `).join('');
+        container.querySelectorAll('.oid-history-item').forEach(item => {
+            item.addEventListener('click', () => {
+                const q = history[item.dataset.index];
+                document.getElementById('oid_browser_target').value = q.serial || q.ip;
+                document.getElementById('oid_browser_mode').value = q.mode;
+                document.getElementById('oid_browser_oids').value = (q.oids || []).join(' ');
+            });
+        });
+    } catch (e) {
+        window.__pm_shared.error('Failed to load OID browser history:', e);
+    }
+}
+
+// ===== Guest Access =====
+
+async function createGuestLink() {
+    const label = document.getElementById('guest_link_label').value.trim();
+    const ttl = parseInt(document.getElementById('guest_link_ttl').value, 10) || 60;
+    try {
+        const r = await fetch('/api/guest-links', {
+            method: 'POST',
+            headers: { 'Content-Type': 'application/json' },
+            body: JSON.stringify({ label, ttl_minutes: ttl })
+        });
+        if (!r.ok) throw new Error(await r.text());
+        const result = await r.json();
+        document.getElementById('guest_link_url').value = result.url;
+        document.getElementById('guest_link_created').classList.remove('hidden');
+        document.getElementById('guest_link_label').value = '';
+        window.__pm_shared.showToast('Guest link created. It is only shown once.', 'success');
+        loadGuestLinks();
+    } catch (e) {
+        window.__pm_shared.showToast('Failed to create guest link: ' + e.message, 'error');
+    }
+}
+
+async function revokeGuestLink(id) {
+    try {
+        const r = await fetch('/api/guest-links?id=' + encodeURIComponent(id), { method: 'DELETE' });
+        if (!r.ok) throw new Error(await r.text());
+        window.__pm_shared.showToast('Guest link revoked', 'success');
+        loadGuestLinks();
+    } catch (e) {
+        window.__pm_shared.showToast('Failed to revoke guest link: ' + e.message, 'error');
+    }
+}
+
+async function loadGuestLinks() {
+    const container = document.getElementById('guest_links_list');
+    if (!container) return;
+    try {
+        const r = await fetch('/api/guest-links');
+        if (!r.ok) return;
+        const links = await r.json();
+        if (links.length === 0) {
+            container.innerHTML = '<div class="muted-text">No guest links</div>';
+            return;
+        }
+        const now = Date.now();
+        let html = '<table class="simple-table" style="width:100%;"><thead><tr><th>Label</th><th>Created</th><th>Expires</th><th>Status</th><th></th></tr></thead><tbody>';
+        links.forEach(link => {
+            const expired = new Date(link.expires_at).getTime() <= now;
+            const status = link.revoked_at ? 'Revoked by ' + escapeHtml(link.revoked_by || 'unknown') : (expired ? 'Expired' : 'Active');
+            html += '<tr><td>' + escapeHtml(link.label) + '</td>';
+            html += '<td>' + new Date(link.created_at).toLocaleString() + ' by ' + escapeHtml(link.created_by) + '</td>';
+            html += '<td>' + new Date(link.expires_at).toLocaleString() + '</td>';
+            html += '<td>' + status + '</td>';
+            html += `<td style="white-space:nowrap"><button class="guest-link-audit-btn" data-id="${escapeHtml(link.id)}">Activity</button>`;
+            if (!link.revoked_at && !expired) {
+                html += ` <button class="delete guest-link-revoke-btn" data-id="${escapeHtml(link.id)}">Revoke</button>`;
+            }
+            html += '</td></tr>';
+        });
+        html += '</tbody></table>';
+        container.innerHTML = html;
+        container.querySelectorAll('.guest-link-revoke-btn').forEach(btn => {
+            btn.addEventListener('click', () => revokeGuestLink(btn.dataset.id));
+        });
+        container.querySelectorAll('.guest-link-audit-btn').forEach(btn => {
+            btn.addEventListener('click', () => loadGuestLinkAudit(btn.dataset.id));
+        });
+    } catch (e) {
+        window.__pm_shared.error('Failed to load guest links:', e);
+    }
+}
+
+async function loadGuestLinkAudit(id) {
+    const container = document.getElementById('guest_link_audit');
+    try {
+        const r = await fetch('/api/guest-links/audit?limit=200&id=' + encodeURIComponent(id));
+        if (!r.ok) throw new Error(await r.text());
+        const entries = await r.json();
+        if (entries.length === 0) {
+            container.innerHTML = '<div class="muted-text">No activity under this link</div>';
+            return;
+        }
+        let html = '<table class="simple-table" style="width:100%;"><thead><tr><th>Time</th><th>Request</th><th>Status</th><th>From</th></tr></thead><tbody>';
+        entries.forEach(e => {
+            html += '<tr><td>' + new Date(e.time).toLocaleString() + '</td>';
+            html += '<td style="font-family:monospace">' + escapeHtml(e.method + ' ' + e.path) + '</td>';
+            html += '<td>' + e.status + '</td>';
+            html += '<td>' + escapeHtml(e.remote_addr) + '</td></tr>';
+        });
+        html += '</tbody></table>';
+        container.innerHTML = html;
+    } catch (e) {
+        container.textContent = 'Failed to load guest activity: ' + e.message;
+    }
+}
+
 // Show saved device details in modal
 async function showSavedDeviceDetails(serial) {
     if (!serial) return;
@@ -2071,7 +2290,7 @@ function loadSavedRanges() {
         // On load, estimate expansion and warn if too large
         try {
             const cnt = estimateRangeCount(txt);
-            const MAX_ADDRS = 10000;
+            const MAX_ADDRS = 65536;
             if (cnt > MAX_ADDRS) {
                 window.__pm_shared.showToast(`Saved ranges expand to ${cnt} addresses which exceeds the allowed maximum of ${MAX_ADDRS}. Manual IP scanning may be disabled. Reduce ranges or enable passive discovery.`, 'error', 8000);
             }
@@ -2081,12 +2300,56 @@ function loadSavedRanges() {
     })
 }
 
+// Keep the section revisions current after our own successful saves so the
+// next save from this page isn't mistaken for a stale edit.
+async function rememberSettingsRevisions(r) {
+    if (r.ok) {
+        try {
+            const saved = await r.clone().json();
+            if (saved && saved.revisions) settingsRevisions = saved.revisions;
+        } catch (e) { /* not JSON */ }
+    }
+    return r;
+}
+
+// Describe a failed /settings response; schema validation failures (HTTP 400
+// with an errors list) are listed as "field: message".
+async function settingsErrorText(r) {
+    const text = await r.text();
+    try {
+        const body = JSON.parse(text);
+        if (body && Array.isArray(body.errors) && body.errors.length) {
+            return body.errors.map(e => `${e.field}: ${e.message}`).join('; ');
+        }
+        if (body && body.error) return body.error;
+    } catch (e) { /* plain text error */ }
+    return text;
+}
+
+// POST /settings, asking for confirmation when the agent reports that the
+// discovery change exceeds its safety limits (HTTP 422 with a simulation body).
+async function postSettingsWithDiscoveryCheck(payload) {
+    const post = body => fetch('/settings', { method: 'POST', headers: { 'Content-Type': 'application/json' }, body: JSON.stringify(body) }).then(rememberSettingsRevisions);
+    const r = await post(payload);
+    if (r.status !== 422) return r;
+    let sim = null;
+    try { sim = await r.clone().json(); } catch (e) { return r; }
+    if (!sim || !sim.requires_override) return r;
+    const minutes = Math.ceil((sim.estimated_duration_seconds || 0) / 60);
+    const reasons = (sim.violations || []).join('; ');
+    const confirmed = await window.__pm_shared.showConfirm(
+        `This discovery change would scan ${sim.total_addresses} addresses (about ${minutes} min per scan): ${reasons}. Save anyway?`,
+        'Large discovery scope', true);
+    if (!confirmed) throw new Error('discovery change cancelled');
+    return post(Object.assign({}, payload, { override_safety_limits: true }));
+}
+
 function saveRanges() {
     let txt = document.getElementById('ranges_text').value;
-    // Client-side guard: prevent saving ranges that expand beyond safe threshold
+    // Client-side guard: prevent saving ranges that expand beyond the hard limit
     try {
         const cnt = estimateRangeCount(txt);
-        const MAX_ADDRS = 10000; // keep consistent with server-side policy
+        const MAX_ADDRS = 65536; // keep consistent with server-side policy
         if (cnt > MAX_ADDRS) {
             window.__pm_shared.showToast(`Cannot save ranges: expansion would produce ${cnt} addresses (over max ${MAX_ADDRS})`, 'error', 6000);
             return;
@@ -2094,7 +2357,7 @@ function saveRanges() {
     } catch (e) {
         // If estimation fails, proceed and let server validate
     }
-    fetch('/settings', { method: 'POST', headers: { 'Content-Type': 'application/json' }, body: JSON.stringify({ discovery: { ranges_text: txt } }) })
+    postSettingsWithDiscoveryCheck({ discovery: { ranges_text: txt } })
         .then(async r => {
                 if (!r.ok) { 
                 let t = await r.text(); 
@@ -2195,6 +2458,7 @@ function ipToUint32_js(ip) {
 
 function clearRanges() {
     fetch('/settings', { method: 'POST', headers: { 'Content-Type': 'application/json' }, body: JSON.stringify({ discovery: { ranges_text: '' } }) })
+        .then(rememberSettingsRevisions)
         .then(() => loadSavedRanges())
 }
 
@@ -2221,7 +2485,7 @@ function updateSubnetDisplay() {
                             method: 'POST',
                             headers: { 'Content-Type': 'application/json' },
                             body: JSON.stringify({ discovery: { subnet_scan: false } })
-                        }).then(r => {
+                        }).then(rememberSettingsRevisions).then(r => {
                             if (!r.ok) {
                                 window.__pm_shared.warn('Failed to persist subnet_scan=false');
                             }
@@ -2478,7 +2742,10 @@ async function loadDeviceMetrics(serial, targetId) {
 
     // Toggle to show/hide the datetime selector (hidden by default)
     const toggleTarget = targetId || '';
-    html += '<div style="display:flex;justify-content:flex-end;margin-bottom:8px">';
+    const exportBase = '/api/devices/metrics/export?serial=' + encodeURIComponent(serial) + '&format=';
+    html += '<div style="display:flex;justify-content:flex-end;gap:6px;margin-bottom:8px">';
+    html += '<a class="ghost-btn" href="' + exportBase + 'csv" download title="Download the complete metrics history as CSV" style="padding:6px 10px;font-size:13px;text-decoration:none">Export CSV</a>';
+    html += '<a class="ghost-btn" href="' + exportBase + 'parquet" download title="Download the complete metrics history as Parquet" style="padding:6px 10px;font-size:13px;text-decoration:none">Export Parquet</a>';
     html += '<button id="metrics_toggle_time_btn" data-action="toggle-time" data-target="' + toggleTarget + '" aria-expanded="false" style="padding:6px 10px;font-size:13px">Show time selector</button>';
     html += '</div>';
 
@@ -3240,6 +3507,46 @@ function loadUnknowns() {
 
 setInterval(updateMetrics, 2000);
 
+// Degraded-mode banner: lists subsystems that are down, degraded or
+// disabled by policy. Refreshed on SSE status_changed events, and polled
+// when proxied through the server (which does not relay agent events).
+const SUBSYSTEM_LABELS = {
+    db: 'Database',
+    scanner: 'Scanner',
+    upload: 'Server uploads',
+    spooler: 'Print spooler',
+    proxy: 'Remote proxy',
+};
+
+function renderDegradedBanner(summary) {
+    const banner = document.getElementById('degraded_banner');
+    if (!banner) return;
+    const problems = ((summary && summary.subsystems) || []).filter(s => ['down', 'degraded', 'disabled'].includes(s.state));
+    if (problems.length === 0) {
+        banner.classList.add('hidden');
+        banner.innerHTML = '';
+        return;
+    }
+    banner.classList.toggle('down', problems.some(s => s.state === 'down'));
+    const items = problems.map(s => {
+        const label = SUBSYSTEM_LABELS[s.name] || s.name;
+        const reason = s.reason ? ': ' + escapeHtml(s.reason) : '';
+        return '<div><strong>' + escapeHtml(label) + ' ' + escapeHtml(s.state) + '</strong>' + reason + '</div>';
+    });
+    banner.innerHTML = '<div>Agent is running in degraded mode.</div>' + items.join('');
+    banner.classList.remove('hidden');
+}
+
+async function refreshStatusSummary() {
+    try {
+        const resp = await fetch('/api/v1/status/summary');
+        if (!resp.ok) return;
+        renderDegradedBanner(await resp.json());
+    } catch (err) {
+        window.__pm_shared.warn('Failed to load status summary', err);
+    }
+}
+
 // Connect to SSE for real-time updates (replaces polling)
 // If this page is being served through the central server proxy, the server
 // injects a meta tag `X-PrintMaster-Proxied`. In that case we must connect
@@ -3249,12 +3556,22 @@ const __isProxied = !!document.querySelector('meta[http-equiv="X-PrintMaster-Pro
 const __ssePath = __isProxied ? '/api/events' : '/events';
 window.__pm_shared.log('[SSE] connecting to', __ssePath, 'proxied=', __isProxied);
 const eventSource = new EventSource(__ssePath);
+if (__isProxied) {
+    refreshStatusSummary();
+    setInterval(refreshStatusSummary, 60000);
+}
 
 eventSource.addEventListener('connected', (e) => {
     window.__pm_shared.log('SSE connected:', e.data);
     // Load initial logs when connected
     updateLog();
     refreshServerConnectionUI({ silent: true });
+    refreshStatusSummary();
+});
+
+eventSource.addEventListener('status_changed', (e) => {
+    window.__pm_shared.log('Subsystem status changed:', e.data);
+    refreshStatusSummary();
 });
 
 eventSource.addEventListener('server_status', (e) => {
@@ -3876,6 +4193,25 @@ document.addEventListener('DOMContentLoaded', async function () {
         clearDbBtn.addEventListener('click', clearDatabase);
     }
 
+    // SNMP OID browser
+    const oidRunBtn = document.getElementById('oid_browser_run_btn');
+    if (oidRunBtn) {
+        oidRunBtn.addEventListener('click', runOIDBrowserQuery);
+        loadOIDBrowserHistory();
+    }
+
+    // Guest access links
+    const guestLinkCreateBtn = document.getElementById('guest_link_create_btn');
+    if (guestLinkCreateBtn) {
+        guestLinkCreateBtn.addEventListener('click', createGuestLink);
+        document.getElementById('guest_link_copy_btn').addEventListener('click', () => {
+            navigator.clipboard.writeText(document.getElementById('guest_link_url').value)
+                .then(() => window.__pm_shared.showToast('Guest link copied', 'success'))
+                .catch(() => window.__pm_shared.showToast('Copy failed; select the link and copy it manually', 'error'));
+        });
+        loadGuestLinks();
+    }
+
     // Log buttons
     const copyLogsBtn = document.getElementById('copy_logs_btn');
     if (copyLogsBtn) {
@@ -4098,6 +4434,9 @@ function restoreAgentActiveTab() {
 
 // Track server-managed state globally
 let serverManagedSections = new Set();
+// Section revisions from the last settings load; saves based on stale
+// revisions are refused with 409 so they can't overwrite newer values
+let settingsRevisions = {};
 
 // Apply server-managed state to UI elements - disables fields and shows badge
 function applyServerManagedState() {
@@ -4145,11 +4484,15 @@ function applyServerManagedState() {
             'discovery_arp_enabled', 'discovery_icmp_enabled', 'discovery_tcp_enabled',
             'discovery_snmp_enabled', 'discovery_mdns_enabled',
             'discovery_live_mdns_enabled', 'discovery_live_wsd_enabled',
-            'discovery_live_ssdp_enabled', 'discovery_live_snmptrap_enabled',
+            'discovery_live_ssdp_enabled', 'discovery_live_snmptrap_enabled', 'discovery_snmptrap_fallback_port',
             'discovery_live_llmnr_enabled', 'passive_discovery_enabled',
             'metrics_rescan_enabled', 'metrics_rescan_interval',
             'auto_discover_checkbox', 'autosave_checkbox',
             'show_discover_button_anyway', 'show_discovered_devices_anyway',
+            'discovery_persist_allow_patterns', 'discovery_persist_ignore_sys_object_ids',
+            'discovery_authorized_scan_scope',
+            'discovery_service_scan_enabled', 'discovery_service_scan_max_per_minute', 'discovery_service_scan_interval_hours',
+            'discovery_meter_read_enabled', 'discovery_meter_read_day_of_month',
             'ranges_input'
         ];
         for (const id of discoveryInputIds) {
@@ -4160,6 +4503,51 @@ function applyServerManagedState() {
 }
 
 // Load settings from /settings endpoint and populate ALL UI elements
+const SNMP_TRAP_STATE_LABELS = {
+    stopped: 'Stopped',
+    starting: 'Starting…',
+    listening: 'Receiving traps',
+    fallback: 'Fallback port',
+    failed: 'Not receiving traps'
+};
+
+// refreshSNMPTrapStatus shows whether the trap listener actually bound a
+// port, so a listener that lacks privileges does not fail silently.
+async function refreshSNMPTrapStatus() {
+    const badge = document.getElementById('snmptrap_status');
+    if (!badge) return;
+    const detail = document.getElementById('snmptrap_status_detail');
+    const hint = document.getElementById('snmptrap_status_hint');
+    try {
+        const res = await fetch('/api/v1/traps/status');
+        if (!res.ok) throw new Error('HTTP ' + res.status);
+        const st = await res.json();
+        badge.dataset.state = st.state;
+        badge.textContent = SNMP_TRAP_STATE_LABELS[st.state] || st.state;
+        const parts = [];
+        if (st.port) parts.push('UDP ' + st.port);
+        if (st.state !== 'stopped') {
+            parts.push((st.privileged_bind ? 'can bind 162: ' : 'cannot bind 162: ') + st.capability);
+            parts.push(st.traps_received + ' received' + (st.last_trap_at ? ', last ' + new Date(st.last_trap_at).toLocaleString() : ''));
+        }
+        if (st.error) parts.push(st.error);
+        detail.textContent = parts.join(' · ');
+        hint.textContent = st.hint || '';
+        hint.style.display = st.hint ? '' : 'none';
+    } catch (err) {
+        badge.dataset.state = 'failed';
+        badge.textContent = 'Unknown';
+        detail.textContent = 'Could not load trap listener status';
+        hint.style.display = 'none';
+    }
+}
+
+// The listener binds asynchronously after the setting is saved
+function scheduleSNMPTrapStatusRefresh() {
+    setTimeout(refreshSNMPTrapStatus, 1500);
+    setTimeout(refreshSNMPTrapStatus, 5000);
+}
+
 function loadSettings() {
     return fetch('/settings').then(async r => {
         if (!r.ok) { window.__pm_shared.warn('failed to load settings'); return; }
@@ -4173,6 +4561,7 @@ function loadSettings() {
 
         // Track which sections are server-managed
         serverManagedSections = new Set(s.managed_sections || []);
+        settingsRevisions = s.revisions || {};
         applyServerManagedState();
 
         // Store web settings globally for use in device modal rendering
@@ -4184,6 +4573,19 @@ function loadSettings() {
         // Logging settings
         document.getElementById('dev_debug_logging').value = log.level || 'info';
         document.getElementById('dev_dump_parse_debug').checked = !!log.dump_parse_debug;
+
+        // Desktop notification settings (agent-local)
+        const notify = s.notifications || {};
+        const notifyEnabled = document.getElementById('notify_desktop_enabled');
+        if (notifyEnabled) {
+            notifyEnabled.checked = notify.desktop_enabled !== false;
+            document.getElementById('notify_scan_complete').checked = notify.scan_complete !== false;
+            document.getElementById('notify_device_errors').checked = notify.device_errors !== false;
+            const unavailable = document.getElementById('desktop_notifications_unavailable');
+            if (unavailable) {
+                unavailable.style.display = s.desktop_notifications_available ? 'none' : 'block';
+            }
+        }
         
         // SNMP settings - basic
         document.getElementById('dev_snmp_version').value = snmp.version || '2c';
@@ -4210,6 +4612,10 @@ function loadSettings() {
         if (epsonRemoteToggle) {
             epsonRemoteToggle.checked = feat.epson_remote_mode_enabled === true;
         }
+        const statusPageOCRToggle = document.getElementById('dev_status_page_ocr');
+        if (statusPageOCRToggle) {
+            statusPageOCRToggle.checked = feat.status_page_ocr_enabled === true;
+        }
         
         // Discovery settings
         document.getElementById('dev_discover_concurrency').value = disc.concurrency || 50;
@@ -4227,11 +4633,21 @@ function loadSettings() {
         document.getElementById('discovery_live_wsd_enabled').checked = disc.auto_discover_live_wsd === true;
         document.getElementById('discovery_live_ssdp_enabled').checked = disc.auto_discover_live_ssdp === true;
         document.getElementById('discovery_live_snmptrap_enabled').checked = disc.auto_discover_live_snmptrap === true;
+        document.getElementById('discovery_snmptrap_fallback_port').value = disc.snmp_trap_fallback_port ?? 10162;
+        refreshSNMPTrapStatus();
         document.getElementById('discovery_live_llmnr_enabled').checked = disc.auto_discover_live_llmnr === true;
         document.getElementById('metrics_rescan_enabled').checked = disc.metrics_rescan_enabled === true;
         document.getElementById('metrics_rescan_interval').value = disc.metrics_rescan_interval_minutes ?? 60;
         document.getElementById('auto_discover_checkbox').checked = disc.auto_discover_enabled === true;
         document.getElementById('autosave_checkbox').checked = disc.autosave_discovered_devices === true;
+        document.getElementById('discovery_persist_allow_patterns').value = disc.persist_allow_patterns || '';
+        document.getElementById('discovery_persist_ignore_sys_object_ids').value = disc.persist_ignore_sys_object_ids || '';
+        document.getElementById('discovery_authorized_scan_scope').value = disc.authorized_scan_scope || '';
+        document.getElementById('discovery_service_scan_enabled').checked = disc.service_scan_enabled === true;
+        document.getElementById('discovery_service_scan_max_per_minute').value = disc.service_scan_max_per_minute ?? 6;
+        document.getElementById('discovery_service_scan_interval_hours').value = disc.service_scan_interval_hours ?? 24;
+        document.getElementById('discovery_meter_read_enabled').checked = disc.meter_read_enabled === true;
+        document.getElementById('discovery_meter_read_day_of_month').value = disc.meter_read_day_of_month ?? 1;
 
         // Load the "Show Anyway" toggle states
         document.getElementById('show_discover_button_anyway').checked = disc.show_discover_button_anyway === true;
@@ -4665,7 +5081,8 @@ function saveDevSettings() {
     }
     const featuresSettings = {
         asset_id_regex: document.getElementById('dev_asset_id_regex').value || '',
-        epson_remote_mode_enabled: document.getElementById('dev_epson_remote_mode')?.checked ?? false
+        epson_remote_mode_enabled: document.getElementById('dev_epson_remote_mode')?.checked ?? false,
+        status_page_ocr_enabled: document.getElementById('dev_status_page_ocr')?.checked ?? false
     };
     const loggingSettings = {
         level: logLevel,
@@ -4673,9 +5090,10 @@ function saveDevSettings() {
     };
     // POST settings as part of the unified /settings endpoint
     fetch('/settings', { method: 'POST', headers: { 'Content-Type': 'application/json' }, body: JSON.stringify({ snmp: snmpSettings, features: featuresSettings, logging: loggingSettings }) })
+        .then(rememberSettingsRevisions)
         .then(async r => { 
             if (!r.ok) { 
-                const t = await r.text(); 
+                const t = await settingsErrorText(r); 
                 window.__pm_shared.error('Save failed:', t); 
                 window.__pm_shared.showToast('Save failed: ' + t, 'error');
                 return; 
@@ -4770,6 +5188,9 @@ function addAutoSaveHandlers() {
     // Auto-save ranges when the textarea loses focus
     const rangesEl = document.getElementById('ranges_text');
     if (rangesEl) { rangesEl.addEventListener('blur', window.__settingsChangeHandler); }
+    document.getElementById('discovery_persist_allow_patterns')?.addEventListener('blur', window.__settingsChangeHandler);
+    document.getElementById('discovery_persist_ignore_sys_object_ids')?.addEventListener('blur', window.__settingsChangeHandler);
+    document.getElementById('discovery_authorized_scan_scope')?.addEventListener('blur', window.__settingsChangeHandler);
     // Auto Discover and Autosave toggles (with UI effects)
     window.__autoDiscoverHandler = () => { toggleAutoDiscoverUI(); saveAllSettings().then(() => showAutosaveFeedback()); };
     window.__autosaveHandler = () => { toggleAutosaveUI(); saveAllSettings().then(() => showAutosaveFeedback()); };
@@ -4828,10 +5249,17 @@ function addAutoSaveHandlers() {
     document.getElementById('discovery_live_wsd_enabled')?.addEventListener('change', window.__settingsChangeHandler);
     document.getElementById('discovery_live_ssdp_enabled')?.addEventListener('change', window.__settingsChangeHandler);
     document.getElementById('discovery_live_snmptrap_enabled')?.addEventListener('change', window.__settingsChangeHandler);
+    document.getElementById('discovery_live_snmptrap_enabled')?.addEventListener('change', scheduleSNMPTrapStatusRefresh);
+    document.getElementById('discovery_snmptrap_fallback_port')?.addEventListener('change', window.__settingsChangeHandler);
     document.getElementById('discovery_live_llmnr_enabled')?.addEventListener('change', window.__settingsChangeHandler);
     document.getElementById('metrics_rescan_enabled')?.addEventListener('change', window.__settingsChangeHandler);
     // Remove IP scanning handlers when autosave disabled
     document.getElementById('metrics_rescan_interval')?.addEventListener('change', window.__settingsChangeHandler);
+    document.getElementById('discovery_service_scan_enabled')?.addEventListener('change', window.__settingsChangeHandler);
+    document.getElementById('discovery_service_scan_max_per_minute')?.addEventListener('change', window.__settingsChangeHandler);
+    document.getElementById('discovery_service_scan_interval_hours')?.addEventListener('change', window.__settingsChangeHandler);
+    document.getElementById('discovery_meter_read_enabled')?.addEventListener('change', window.__settingsChangeHandler);
+    document.getElementById('discovery_meter_read_day_of_month')?.addEventListener('change', window.__settingsChangeHandler);
 
     // Spooler/local printer tracking settings
     window.__spoolerEnabledHandler = function() {
@@ -4849,10 +5277,14 @@ function addAutoSaveHandlers() {
     // Developer settings
     document.getElementById('dev_debug_logging')?.addEventListener('change', window.__settingsChangeHandler);
     document.getElementById('dev_dump_parse_debug')?.addEventListener('change', window.__settingsChangeHandler);
+    document.getElementById('notify_desktop_enabled')?.addEventListener('change', window.__settingsChangeHandler);
+    document.getElementById('notify_scan_complete')?.addEventListener('change', window.__settingsChangeHandler);
+    document.getElementById('notify_device_errors')?.addEventListener('change', window.__settingsChangeHandler);
     document.getElementById('dev_snmp_community')?.addEventListener('change', window.__settingsChangeHandler);
     document.getElementById('dev_snmp_timeout')?.addEventListener('change', window.__settingsChangeHandler);
     document.getElementById('dev_snmp_retries')?.addEventListener('change', window.__settingsChangeHandler);
     document.getElementById('dev_epson_remote_mode')?.addEventListener('change', window.__settingsChangeHandler);
+    document.getElementById('dev_status_page_ocr')?.addEventListener('change', window.__settingsChangeHandler);
     document.getElementById('dev_discover_concurrency')?.addEventListener('change', window.__settingsChangeHandler);
     document.getElementById('dev_asset_id_regex')?.addEventListener('change', window.__settingsChangeHandler);
 }
@@ -4870,6 +5302,9 @@ function removeAutoSaveHandlers() {
     document.getElementById('discovery_mdns_enabled')?.removeEventListener('change', window.__settingsChangeHandler);
     const rangesEl = document.getElementById('ranges_text');
     if (rangesEl) { rangesEl.removeEventListener('blur', window.__settingsChangeHandler); }
+    document.getElementById('discovery_persist_allow_patterns')?.removeEventListener('blur', window.__settingsChangeHandler);
+    document.getElementById('discovery_persist_ignore_sys_object_ids')?.removeEventListener('blur', window.__settingsChangeHandler);
+    document.getElementById('discovery_authorized_scan_scope')?.removeEventListener('blur', window.__settingsChangeHandler);
     // Note: auto_discover_checkbox and autosave_checkbox use separate handlers stored in window
     const autoDiscoverEl = document.getElementById('auto_discover_checkbox');
     if (autoDiscoverEl && window.__autoDiscoverHandler) { autoDiscoverEl.removeEventListener('change', window.__autoDiscoverHandler); }
@@ -4887,10 +5322,17 @@ function removeAutoSaveHandlers() {
     document.getElementById('discovery_live_wsd_enabled')?.removeEventListener('change', window.__settingsChangeHandler);
     document.getElementById('discovery_live_ssdp_enabled')?.removeEventListener('change', window.__settingsChangeHandler);
     document.getElementById('discovery_live_snmptrap_enabled')?.removeEventListener('change', window.__settingsChangeHandler);
+    document.getElementById('discovery_live_snmptrap_enabled')?.removeEventListener('change', scheduleSNMPTrapStatusRefresh);
+    document.getElementById('discovery_snmptrap_fallback_port')?.removeEventListener('change', window.__settingsChangeHandler);
     document.getElementById('discovery_live_llmnr_enabled')?.removeEventListener('change', window.__settingsChangeHandler);
     document.getElementById('metrics_rescan_enabled')?.removeEventListener('change', window.__settingsChangeHandler);
     document.getElementById('ip_scanning_enabled')?.removeEventListener('change', window.__ipScanningHandler);
     document.getElementById('metrics_rescan_interval')?.removeEventListener('change', window.__settingsChangeHandler);
+    document.getElementById('discovery_service_scan_enabled')?.removeEventListener('change', window.__settingsChangeHandler);
+    document.getElementById('discovery_service_scan_max_per_minute')?.removeEventListener('change', window.__settingsChangeHandler);
+    document.getElementById('discovery_service_scan_interval_hours')?.removeEventListener('change', window.__settingsChangeHandler);
+    document.getElementById('discovery_meter_read_enabled')?.removeEventListener('change', window.__settingsChangeHandler);
+    document.getElementById('discovery_meter_read_day_of_month')?.removeEventListener('change', window.__settingsChangeHandler);
 
     // Spooler settings
     const spoolerEnabledEl = document.getElementById('spooler_enabled');
@@ -4901,10 +5343,14 @@ function removeAutoSaveHandlers() {
 
     document.getElementById('dev_debug_logging')?.removeEventListener('change', window.__settingsChangeHandler);
     document.getElementById('dev_dump_parse_debug')?.removeEventListener('change', window.__settingsChangeHandler);
+    document.getElementById('notify_desktop_enabled')?.removeEventListener('change', window.__settingsChangeHandler);
+    document.getElementById('notify_scan_complete')?.removeEventListener('change', window.__settingsChangeHandler);
+    document.getElementById('notify_device_errors')?.removeEventListener('change', window.__settingsChangeHandler);
     document.getElementById('dev_snmp_community')?.removeEventListener('change', window.__settingsChangeHandler);
     document.getElementById('dev_snmp_timeout')?.removeEventListener('change', window.__settingsChangeHandler);
     document.getElementById('dev_snmp_retries')?.removeEventListener('change', window.__settingsChangeHandler);
     document.getElementById('dev_epson_remote_mode')?.removeEventListener('change', window.__settingsChangeHandler);
+    document.getElementById('dev_status_page_ocr')?.removeEventListener('change', window.__settingsChangeHandler);
     document.getElementById('dev_discover_concurrency')?.removeEventListener('change', window.__settingsChangeHandler);
     document.getElementById('dev_asset_id_regex')?.removeEventListener('change', window.__settingsChangeHandler);
 }
@@ -4930,6 +5376,11 @@ async function saveAllSettings(btn) {
             // Device Identification
             snmp_enabled: document.getElementById('discovery_snmp_enabled')?.checked ?? true,
 
+            // Persistence Filters
+            persist_allow_patterns: document.getElementById('discovery_persist_allow_patterns')?.value ?? '',
+            persist_ignore_sys_object_ids: document.getElementById('discovery_persist_ignore_sys_object_ids')?.value ?? '',
+            authorized_scan_scope: document.getElementById('discovery_authorized_scan_scope')?.value ?? '',
+
             // Passive Discovery
             auto_discover_enabled: document.getElementById('auto_discover_checkbox')?.checked ?? false,
             autosave_discovered_devices: document.getElementById('autosave_checkbox')?.checked ?? false,
@@ -4940,6 +5391,7 @@ async function saveAllSettings(btn) {
             auto_discover_live_wsd: document.getElementById('discovery_live_wsd_enabled')?.checked ?? true,
             auto_discover_live_ssdp: document.getElementById('discovery_live_ssdp_enabled')?.checked ?? false,
             auto_discover_live_snmptrap: document.getElementById('discovery_live_snmptrap_enabled')?.checked ?? false,
+            snmp_trap_fallback_port: Math.min(65535, Math.max(1024, parseInt(document.getElementById('discovery_snmptrap_fallback_port')?.value, 10) || 10162)),
             auto_discover_live_llmnr: document.getElementById('discovery_live_llmnr_enabled')?.checked ?? false,
 
             // Metrics Monitoring
@@ -4956,6 +5408,13 @@ async function saveAllSettings(btn) {
                     return iv;
                 })(),
 
+            // Service Scan
+            service_scan_enabled: document.getElementById('discovery_service_scan_enabled')?.checked ?? false,
+            service_scan_max_per_minute: Math.min(60, Math.max(1, parseInt(document.getElementById('discovery_service_scan_max_per_minute')?.value, 10) || 6)),
+            service_scan_interval_hours: Math.min(720, Math.max(1, parseInt(document.getElementById('discovery_service_scan_interval_hours')?.value, 10) || 24)),
+            meter_read_enabled: document.getElementById('discovery_meter_read_enabled')?.checked ?? false,
+            meter_read_day_of_month: Math.min(28, Math.max(1, parseInt(document.getElementById('discovery_meter_read_day_of_month')?.value, 10) || 1)),
+
             // Performance
             concurrency: parseInt(document.getElementById('dev_discover_concurrency').value) || 50
         };
@@ -4992,7 +5451,8 @@ async function saveAllSettings(btn) {
         const featuresSettings = {
             credentials_enabled: document.getElementById('enable_saved_credentials')?.checked ?? true,
             asset_id_regex: document.getElementById('dev_asset_id_regex').value || '',
-            epson_remote_mode_enabled: document.getElementById('dev_epson_remote_mode')?.checked ?? false
+            epson_remote_mode_enabled: document.getElementById('dev_epson_remote_mode')?.checked ?? false,
+            status_page_ocr_enabled: document.getElementById('dev_status_page_ocr')?.checked ?? false
         };
 
         // Compose logging settings (agent-local)
@@ -5001,6 +5461,13 @@ async function saveAllSettings(btn) {
             dump_parse_debug: document.getElementById('dev_dump_parse_debug').checked
         };
 
+        // Compose desktop notification settings (agent-local)
+        const notificationSettings = {
+            desktop_enabled: document.getElementById('notify_desktop_enabled')?.checked ?? true,
+            scan_complete: document.getElementById('notify_scan_complete')?.checked ?? true,
+            device_errors: document.getElementById('notify_device_errors')?.checked ?? true
+        };
+
         // Compose web settings (agent-local)
         const webSettings = {
             enable_http: document.getElementById('enable_http')?.checked ?? true,
@@ -5027,13 +5494,15 @@ async function saveAllSettings(btn) {
             include_virtual_printers: document.getElementById('spooler_include_virtual')?.checked ?? false
         };
 
-        const rUnified = await fetch('/settings', {
-            method: 'POST',
-            headers: { 'Content-Type': 'application/json' },
-            body: JSON.stringify({ discovery: discoverySettings, snmp: snmpSettings, features: featuresSettings, spooler: spoolerSettings, logging: loggingSettings, web: webSettings })
-        });
+        const rUnified = await postSettingsWithDiscoveryCheck({ discovery: discoverySettings, snmp: snmpSettings, features: featuresSettings, spooler: spoolerSettings, logging: loggingSettings, web: webSettings, notifications: notificationSettings, revisions: settingsRevisions });
+        if (rUnified.status === 409) {
+            // Changed elsewhere (server push or another admin) since loaded
+            const conflict = await rUnified.json().catch(() => ({}));
+            await loadSettings();
+            throw new Error((conflict.error || 'Settings changed elsewhere') + '. Reloaded the latest values; review and apply again.');
+        }
         if (!rUnified.ok) {
-            const t = await rUnified.text();
+            const t = await settingsErrorText(rUnified);
             throw new Error('Failed to save settings: ' + t);
         }
 
@@ -5233,6 +5702,10 @@ const SERVER_STATUS_BADGE_META = {
         label: 'Connected',
         title: 'HTTP fallback active'
     },
+    degraded: {
+        label: 'Degraded',
+        title: 'Server requests are failing'
+    },
     disconnected: {
         label: 'Disconnected',
         title: 'No server connection'
@@ -5244,7 +5717,7 @@ function deriveServerConnectionMode(status) {
         return 'disconnected';
     }
     const mode = (status.connection_mode || '').trim().toLowerCase();
-    if (mode === 'live' || mode === 'connected' || mode === 'disconnected') {
+    if (mode === 'live' || mode === 'connected' || mode === 'degraded' || mode === 'disconnected') {
         return mode;
     }
     return status.connected ? 'connected' : 'disconnected';
@@ -5256,9 +5729,11 @@ function updateServerStatusBadge(status) {
     const mode = deriveServerConnectionMode(status);
     const meta = SERVER_STATUS_BADGE_META[mode] || SERVER_STATUS_BADGE_META.disconnected;
     badge.textContent = meta.label;
-    badge.title = meta.title;
+    badge.title = mode === 'degraded' && status && status.health
+        ? describeServerHealth(status.health)
+        : meta.title;
     badge.setAttribute('data-mode', mode);
-    ['live', 'connected', 'disconnected'].forEach(state => badge.classList.remove('server-status-' + state));
+    ['live', 'connected', 'degraded', 'disconnected'].forEach(state => badge.classList.remove('server-status-' + state));
     badge.classList.add('server-status-' + mode);
     badge.style.display = 'inline-flex';
 }
@@ -5804,6 +6279,8 @@ function populateServerInfoModal(status) {
             statusLabel = 'Live (WebSocket)';
         } else if (connectionMode === 'connected') {
             statusLabel = 'Connected';
+        } else if (connectionMode === 'degraded') {
+            statusLabel = 'Degraded';
         }
         statusEl.textContent = statusLabel;
         statusEl.classList.toggle('connected', connectionMode !== 'disconnected');
@@ -5822,6 +6299,55 @@ function populateServerInfoModal(status) {
     setField('server_info_last_metrics', describeServerTimestamp(info.last_metrics_upload));
     setField('server_info_agent_token', info.has_agent_token ? 'Yes' : 'No');
     setField('server_info_join_token', info.has_join_token ? 'Yes' : 'No');
+    setField('server_info_health', info.health ? describeServerHealth(info.health) : 'Unknown');
+    const healthEl = document.getElementById('server_info_health');
+    if (healthEl) {
+        healthEl.title = info.health && info.health.last_error ? info.health.last_error : '';
+    }
+    const retryBtn = document.getElementById('server_info_retry_btn');
+    if (retryBtn) {
+        const health = info.health || {};
+        const unhealthy = health.breaker_open || (health.status && health.status !== 'ok' && health.status !== 'unknown');
+        const canRetry = !window.__pm_auth || !window.__pm_auth.currentUser || window.__pm_auth.hasRole('operator');
+        retryBtn.style.display = connected && unhealthy && canRetry ? '' : 'none';
+    }
+}
+
+// describeServerHealth summarizes the upload worker's health classification,
+// including when the circuit breaker will try again.
+function describeServerHealth(health) {
+    if (!health) return 'Unknown';
+    let text = health.message || health.status || 'Unknown';
+    if (health.breaker_open && health.next_attempt) {
+        const next = new Date(health.next_attempt);
+        if (!Number.isNaN(next.getTime())) {
+            text += ' — uploads paused until ' + next.toLocaleTimeString();
+        }
+    } else if (health.consecutive_failures > 0) {
+        text += ' (' + health.consecutive_failures + ' failed attempt' + (health.consecutive_failures === 1 ? '' : 's') + ')';
+    }
+    return text;
+}
+
+async function handleServerRetry() {
+    const btn = document.getElementById('server_info_retry_btn');
+    if (btn) btn.disabled = true;
+    try {
+        const resp = await fetch('/settings/server/retry', { method: 'POST' });
+        if (!resp.ok) {
+            const txt = await resp.text();
+            throw new Error(txt || resp.statusText || 'retry failed');
+        }
+        const payload = await resp.json();
+        window.__pm_shared.showToast('Retrying server connection…', 'info', 3000);
+        if (payload && payload.status) {
+            applyServerConnectionStatus(payload.status);
+        }
+    } catch (err) {
+        window.__pm_shared.showToast('Failed to retry server connection: ' + (err && err.message ? err.message : err), 'error', 4000);
+    } finally {
+        if (btn) btn.disabled = false;
+    }
 }
 
 function describeServerTimestamp(value) {
@@ -5916,6 +6442,10 @@ function initServerConnectionControls() {
         if (unjoinBtn) {
             unjoinBtn.addEventListener('click', handleServerUnjoin);
         }
+        const retryBtn = document.getElementById('server_info_retry_btn');
+        if (retryBtn) {
+            retryBtn.addEventListener('click', handleServerRetry);
+        }
 
         refreshServerConnectionUI();
     } catch (e) {
@@ -0,0 +1,97 @@
+// Mobile asset page opened by scanning a device's QR asset tag.
+(function(){
+  const doc = document;
+  const statusEl = doc.getElementById('asset_status');
+  const titleEl = doc.getElementById('asset_title');
+  const subtitleEl = doc.getElementById('asset_subtitle');
+  const lookupForm = doc.getElementById('asset_lookup_form');
+  const codeInput = doc.getElementById('asset_code');
+  const form = doc.getElementById('asset_form');
+  const infoEl = doc.getElementById('asset_device_info');
+  const assetInput = doc.getElementById('asset_number');
+  const locationInput = doc.getElementById('asset_location');
+  const submitBtn = doc.getElementById('asset_submit');
+
+  let currentCode = '';
+
+  function setStatus(message, kind) {
+    if (!message) {
+      statusEl.classList.add('hidden');
+      statusEl.textContent = '';
+      return;
+    }
+    statusEl.className = 'login-status is-' + (kind || 'info');
+    statusEl.textContent = message;
+  }
+
+  function showDevice(device) {
+    currentCode = device.serial;
+    titleEl.textContent = [device.manufacturer, device.model].filter(Boolean).join(' ') || 'Device';
+    subtitleEl.textContent = 'Serial ' + device.serial;
+    infoEl.textContent = [device.ip, device.hostname].filter(Boolean).join(' · ');
+    assetInput.value = device.asset_number || '';
+    locationInput.value = device.location || '';
+    const locked = device.locked_fields || [];
+    assetInput.disabled = locked.includes('asset_number');
+    locationInput.disabled = locked.includes('location');
+    lookupForm.classList.add('hidden');
+    form.classList.remove('hidden');
+  }
+
+  async function lookup(code) {
+    setStatus('', '');
+    try {
+      const res = await fetch('/api/devices/asset-tag?code=' + encodeURIComponent(code), { credentials: 'same-origin' });
+      if (!res.ok) {
+        throw new Error(res.status === 404 ? 'No device matches this code.' : (await res.text()) || ('HTTP ' + res.status));
+      }
+      showDevice(await res.json());
+    } catch (err) {
+      subtitleEl.textContent = 'Enter a serial or asset number';
+      form.classList.add('hidden');
+      lookupForm.classList.remove('hidden');
+      codeInput.value = code || '';
+      setStatus(err.message || String(err), 'error');
+    }
+  }
+
+  lookupForm.addEventListener('submit', (e) => {
+    e.preventDefault();
+    const code = codeInput.value.trim();
+    if (code) lookup(code);
+  });
+
+  form.addEventListener('submit', async (e) => {
+    e.preventDefault();
+    const payload = { code: currentCode };
+    if (!assetInput.disabled) payload.asset_number = assetInput.value.trim();
+    if (!locationInput.disabled) payload.location = locationInput.value.trim();
+    submitBtn.disabled = true;
+    try {
+      const res = await fetch('/api/devices/asset-tag', {
+        method: 'POST',
+        credentials: 'same-origin',
+        headers: { 'Content-Type': 'application/json' },
+        body: JSON.stringify(payload),
+      });
+      if (!res.ok) {
+        throw new Error(res.status === 403 ? 'Your account cannot update devices.' : (await res.text()) || ('HTTP ' + res.status));
+      }
+      const result = await res.json();
+      showDevice(result);
+      setStatus(result.updated ? 'Saved.' : 'No changes.', 'info');
+    } catch (err) {
+      setStatus(err.message || String(err), 'error');
+    } finally {
+      submitBtn.disabled = false;
+    }
+  });
+
+  const code = new URLSearchParams(window.location.search || '').get('code') || '';
+  if (code) {
+    lookup(code);
+  } else {
+    subtitleEl.textContent = 'Enter a serial or asset number';
+    lookupForm.classList.remove('hidden');
+  }
+})();
@@ -0,0 +1,121 @@
+/* Installable offline shell for the agent UI: registers the service worker,
+ * shows when the page is working from cached data and reports queued
+ * actions as they sync. Only active when the agent is opened directly (not
+ * through the server's proxy). */
+(function () {
+    'use strict';
+    if (!('serviceWorker' in navigator) || window.location.pathname !== '/') return;
+
+    const CACHED_AT_HEADER = 'X-PM-Offline-Cached-At';
+    const REPLAY_INTERVAL_MS = 30000;
+    const state = { cachedAt: null, pending: 0, replayTimer: null };
+
+    function toast(message, type) {
+        const shared = window.__pm_shared;
+        if (shared && typeof shared.showToast === 'function') shared.showToast(message, type || 'info', 4000);
+    }
+
+    function banner() {
+        let el = document.getElementById('pm_offline_banner');
+        if (!el && document.body) {
+            el = document.createElement('div');
+            el.id = 'pm_offline_banner';
+            el.className = 'offline-banner';
+            el.setAttribute('role', 'status');
+            el.style.display = 'none';
+            document.body.appendChild(el);
+        }
+        return el;
+    }
+
+    function render() {
+        const el = banner();
+        if (!el) return;
+        const parts = [];
+        if (state.cachedAt) {
+            const when = new Date(state.cachedAt);
+            parts.push('Agent unreachable — showing data from ' + (isNaN(when) ? state.cachedAt : when.toLocaleString()));
+        } else if (!navigator.onLine) {
+            parts.push('You are offline');
+        }
+        if (state.pending > 0) {
+            parts.push(state.pending + ' queued action' + (state.pending === 1 ? '' : 's') + ' will sync when the agent reconnects');
+        }
+        el.textContent = parts.join('. ');
+        el.style.display = parts.length ? '' : 'none';
+    }
+
+    function requestReplay() {
+        const sw = navigator.serviceWorker.controller;
+        if (sw) sw.postMessage({ type: 'pm-replay' });
+    }
+
+    function scheduleReplay() {
+        if (state.pending > 0 && !state.replayTimer) {
+            state.replayTimer = setInterval(requestReplay, REPLAY_INTERVAL_MS);
+        } else if (state.pending === 0 && state.replayTimer) {
+            clearInterval(state.replayTimer);
+            state.replayTimer = null;
+        }
+    }
+
+    // Watch responses for the service worker's markers instead of touching
+    // every caller: cached data sets a header, queued actions answer 202.
+    const originalFetch = window.fetch.bind(window);
+    window.fetch = async function (...args) {
+        const resp = await originalFetch(...args);
+        try {
+            const cachedAt = resp.headers.get(CACHED_AT_HEADER);
+            if (cachedAt) {
+                state.cachedAt = cachedAt;
+                render();
+            } else if (state.cachedAt && resp.ok) {
+                state.cachedAt = null;
+                render();
+                requestReplay();
+            }
+            if (resp.status === 202 && resp.headers.get('X-PM-Queued')) {
+                toast('Agent unreachable — action queued and will run when it reconnects', 'info');
+            }
+        } catch (e) { /* opaque or unusual response */ }
+        return resp;
+    };
+
+    navigator.serviceWorker.addEventListener('message', (event) => {
+        const msg = event.data || {};
+        if (msg.type !== 'pm-queue') return;
+        state.pending = msg.pending || 0;
+        const replayed = msg.replayed || [];
+        if (replayed.length) {
+            const failed = replayed.filter((r) => !r.ok);
+            if (failed.length) {
+                toast('Synced ' + (replayed.length - failed.length) + ' queued action(s); ' + failed.length + ' failed: ' +
+                    failed.map((r) => r.label + ' (HTTP ' + r.status + ')').join(', '), 'error');
+            } else {
+                toast('Synced ' + replayed.length + ' queued action' + (replayed.length === 1 ? '' : 's'), 'success');
+            }
+            if (typeof window.updatePrinters === 'function') {
+                try { window.updatePrinters(); } catch (e) { /* best effort */ }
+            }
+        }
+        scheduleReplay();
+        render();
+    });
+
+    window.addEventListener('online', () => { render(); requestReplay(); });
+    window.addEventListener('offline', render);
+
+    window.addEventListener('load', () => {
+        navigator.serviceWorker.register('/sw.js', { scope: '/' }).then(() => navigator.serviceWorker.ready).then((reg) => {
+            if (reg.active) {
+                reg.active.postMessage({ type: 'pm-queue-status' });
+                reg.active.postMessage({ type: 'pm-replay' });
+            }
+        }).catch((err) => {
+            // Browsers refuse service workers on plain HTTP (other than
+            // localhost) and on untrusted certificates.
+            if (window.__pm_shared && window.__pm_shared.debug) window.__pm_shared.debug('Offline support unavailable', err);
+        });
+        render();
+    });
+})();
@@ -0,0 +1,236 @@
+/* PrintMaster agent service worker.
+ *
+ * Keeps the UI usable when the agent cannot be reached (flaky Wi-Fi on the
+ * shop floor): the page shell is served from cache, the last device list and
+ * status responses are shown instead of errors, and a few device actions are
+ * queued and replayed once the agent answers again.
+ */
+'use strict';
+
+const VERSION = '{{VERSION}}';
+const SHELL_CACHE = 'pm-agent-shell-' + VERSION;
+const DATA_CACHE = 'pm-agent-data';
+const QUEUE_DB = 'pm-agent-offline';
+const QUEUE_STORE = 'queue';
+const SYNC_TAG = 'pm-agent-queue';
+const CACHED_AT_HEADER = 'X-PM-Offline-Cached-At';
+
+const SHELL_ASSETS = [
+    '/',
+    '/manifest.webmanifest',
+    '/static/shared.css',
+    '/static/style.css',
+    '/static/shared.js',
+    '/static/cards.js',
+    '/static/report.js',
+    '/static/metrics.js',
+    '/static/app.js',
+    '/static/pwa.js',
+    '/static/icon.svg',
+    '/static/flatpickr/flatpickr.min.js',
+    '/static/flatpickr/flatpickr.min.css',
+];
+
+// GET endpoints whose last good response is kept for offline viewing.
+const CACHED_DATA = ['/devices/list', '/devices/discovered', '/api/v1/status/summary', '/api/version'];
+
+// POST endpoints queued while the agent is unreachable. They act on one
+// device and are safe to run late.
+const QUEUEABLE = ['/devices/refresh', '/devices/save', '/devices/metrics/collect'];
+
+self.addEventListener('install', (event) => {
+    event.waitUntil((async () => {
+        const cache = await caches.open(SHELL_CACHE);
+        // Cache what we can; '/' needs a session and may redirect to /login.
+        await Promise.all(SHELL_ASSETS.map(async (url) => {
+            try {
+                const resp = await fetch(url, { credentials: 'same-origin' });
+                if (resp.ok && !resp.redirected) await cache.put(url, resp);
+            } catch (e) { /* offline during install; filled in later */ }
+        }));
+        await self.skipWaiting();
+    })());
+});
+
+self.addEventListener('activate', (event) => {
+    event.waitUntil((async () => {
+        const names = await caches.keys();
+        await Promise.all(names
+            .filter((n) => n.startsWith('pm-agent-shell-') && n !== SHELL_CACHE)
+            .map((n) => caches.delete(n)));
+        await self.clients.claim();
+    })());
+});
+
+self.addEventListener('fetch', (event) => {
+    const req = event.request;
+    const url = new URL(req.url);
+    if (url.origin !== self.location.origin) return;
+
+    if (req.method === 'POST' && QUEUEABLE.includes(url.pathname)) {
+        event.respondWith(sendOrQueue(req));
+        return;
+    }
+    if (req.method !== 'GET') return;
+
+    if (req.mode === 'navigate' && url.pathname === '/') {
+        event.respondWith(shellFirstNetwork(req));
+    } else if (url.pathname.startsWith('/static/') || url.pathname === '/manifest.webmanifest') {
+        event.respondWith(staleWhileRevalidate(req));
+    } else if (CACHED_DATA.includes(url.pathname)) {
+        event.respondWith(networkThenCache(req));
+    }
+});
+
+self.addEventListener('sync', (event) => {
+    if (event.tag === SYNC_TAG) event.waitUntil(replayQueue());
+});
+
+self.addEventListener('message', (event) => {
+    const msg = event.data || {};
+    if (msg.type === 'pm-replay') {
+        event.waitUntil(replayQueue());
+    } else if (msg.type === 'pm-queue-status') {
+        event.waitUntil(queueAll().then((items) => notifyClients({ type: 'pm-queue', pending: items.length, replayed: [] })));
+    } else if (msg.type === 'pm-clear-offline-data') {
+        event.waitUntil(Promise.all([caches.delete(DATA_CACHE), queueClear()]));
+    }
+});
+
+async function shellFirstNetwork(req) {
+    try {
+        const resp = await fetch(req);
+        if (resp.ok && !resp.redirected) {
+            const cache = await caches.open(SHELL_CACHE);
+            await cache.put('/', resp.clone());
+        }
+        return resp;
+    } catch (e) {
+        const cached = await caches.match('/', { cacheName: SHELL_CACHE });
+        if (cached) return cached;
+        return new Response('<!doctype html><title>PrintMaster Agent</title><p>The agent is unreachable and no offline copy is available yet.</p>',
+            { status: 503, headers: { 'Content-Type': 'text/html; charset=utf-8' } });
+    }
+}
+
+async function staleWhileRevalidate(req) {
+    const cache = await caches.open(SHELL_CACHE);
+    const cached = await cache.match(req);
+    const network = fetch(req).then((resp) => {
+        if (resp.ok) cache.put(req, resp.clone());
+        return resp;
+    }).catch(() => null);
+    if (cached) return cached;
+    const resp = await network;
+    return resp || new Response('', { status: 503 });
+}
+
+async function networkThenCache(req) {
+    const cache = await caches.open(DATA_CACHE);
+    try {
+        const resp = await fetch(req);
+        if (resp.ok) {
+            const body = await resp.clone().blob();
+            const headers = new Headers(resp.headers);
+            headers.set(CACHED_AT_HEADER, new Date().toISOString());
+            await cache.put(req, new Response(body, { status: resp.status, headers }));
+        }
+        return resp;
+    } catch (e) {
+        const cached = await cache.match(req);
+        if (cached) return cached;
+        throw e;
+    }
+}
+
+async function sendOrQueue(req) {
+    const body = await req.clone().text();
+    try {
+        return await fetch(req);
+    } catch (e) {
+        const url = new URL(req.url);
+        let label = url.pathname;
+        try {
+            const parsed = JSON.parse(body || '{}');
+            if (parsed && parsed.serial) label += ' ' + parsed.serial;
+            else if (parsed && parsed.ip) label += ' ' + parsed.ip;
+        } catch (err) { /* non-JSON body */ }
+        const id = await queueAdd({
+            url: url.pathname + url.search,
+            method: req.method,
+            contentType: req.headers.get('Content-Type') || '',
+            body,
+            label,
+            queuedAt: new Date().toISOString(),
+        });
+        if (self.registration.sync) {
+            try { await self.registration.sync.register(SYNC_TAG); } catch (err) { /* page triggers replay instead */ }
+        }
+        const pending = (await queueAll()).length;
+        notifyClients({ type: 'pm-queue', pending, replayed: [] });
+        return new Response(JSON.stringify({ queued: true, id, pending, message: 'Agent unreachable; action queued and will run when it reconnects' }),
+            { status: 202, headers: { 'Content-Type': 'application/json', 'X-PM-Queued': '1' } });
+    }
+}
+
+let replaying = null;
+
+function replayQueue() {
+    if (!replaying) {
+        replaying = doReplay().finally(() => { replaying = null; });
+    }
+    return replaying;
+}
+
+async function doReplay() {
+    const items = await queueAll();
+    const replayed = [];
+    for (const item of items) {
+        let resp;
+        try {
+            resp = await fetch(item.url, {
+                method: item.method,
+                headers: item.contentType ? { 'Content-Type': item.contentType } : {},
+                body: item.body,
+                credentials: 'same-origin',
+            });
+        } catch (e) {
+            break; // still offline; keep the rest in order
+        }
+        if (resp.status === 401) break; // session expired; replay after login
+        await queueDelete(item.id);
+        replayed.push({ label: item.label, ok: resp.ok, status: resp.status });
+    }
+    if (replayed.length > 0 || items.length > 0) {
+        notifyClients({ type: 'pm-queue', pending: (await queueAll()).length, replayed });
+    }
+}
+
+async function notifyClients(msg) {
+    const clients = await self.clients.matchAll({ type: 'window' });
+    clients.forEach((c) => c.postMessage(msg));
+}
+
+function openQueue() {
+    return new Promise((resolve, reject) => {
+        const open = indexedDB.open(QUEUE_DB, 1);
+        open.onupgradeneeded = () => open.result.createObjectStore(QUEUE_STORE, { keyPath: 'id', autoIncrement: true });
+        open.onsuccess = () => resolve(open.result);
+        open.onerror = () => reject(open.error);
+    });
+}
+
+async function queueTx(mode, fn) {
+    const db = await openQueue();
+    return new Promise((resolve, reject) => {
+        const tx = db.transaction(QUEUE_STORE, mode);
+        const req = fn(tx.objectStore(QUEUE_STORE));
+        tx.oncomplete = () => { db.close(); resolve(req && req.result); };
+        tx.onerror = () => { db.close(); reject(tx.error); };
+    });
+}
+
+function queueAdd(item) { return queueTx('readwrite', (s) => s.add(item)); }
+function queueAll() { return queueTx('readonly', (s) => s.getAll()).then((r) => r || []); }
+function queueDelete(id) { return queueTx('readwrite', (s) => s.delete(id)); }
+function queueClear() { return queueTx('readwrite', (s) => s.clear()); }
@@ -0,0 +1,29 @@
+package main
+
+import (
+	"printmaster/agent/agent"
+	"printmaster/agent/storage"
+)
+
+// checkWebFingerprintChange warns when the TLS certificate presented by a known
+// device differs from the one recorded previously. Printers rarely rotate
+// their certificates, so a change often means the device was replaced, reset,
+// or that another host is answering on its address.
+func checkWebFingerprintChange(existing *storage.Device, pi agent.PrinterInfo) {
+	if existing == nil || !pi.WebFingerprint.HasTLS() {
+		return
+	}
+	prev := storage.WebFingerprintFromRawData(existing.RawData)
+	if !prev.HasTLS() || prev.CertSHA256 == pi.WebFingerprint.CertSHA256 {
+		return
+	}
+	if appLogger != nil {
+		appLogger.Warn("Device web certificate changed",
+			"serial", pi.Serial,
+			"ip", pi.IP,
+			"previous_sha256", prev.CertSHA256,
+			"current_sha256", pi.WebFingerprint.CertSHA256,
+			"previous_subject", prev.CertSubject,
+			"current_subject", pi.WebFingerprint.CertSubject)
+	}
+}
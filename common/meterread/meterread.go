@@ -0,0 +1,191 @@
+// Package meterread defines certified meter reads: billing-grade counter
+// captures taken by the agent on a schedule, separate from routine metrics.
+// A read records the counters, how the device identified itself when read and
+// the raw OID values the counters were parsed from. Reads are signed by the
+// agent with its own ed25519 key, registered with the server once, so the
+// server can detect tampering before using them for billing reconciliation.
+// The bearer token is not involved: holding it is not enough to forge a read,
+// and reads queued across token rotations still verify.
+package meterread
+
+import (
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// SignatureAlgorithm identifies how Signature was produced.
+const SignatureAlgorithm = "ed25519"
+
+// Reasons a read was captured.
+const (
+	TriggerScheduled = "scheduled"
+	TriggerManual    = "manual"
+)
+
+// ErrUnsigned is returned by Verify for reads without a signature.
+var ErrUnsigned = errors.New("meter read is not signed")
+
+// ErrBadSignature is returned by Verify when the signature does not match.
+var ErrBadSignature = errors.New("meter read signature mismatch")
+
+// IsSignatureRejection reports whether a server rejection reason is a
+// signature failure (ErrUnsigned or ErrBadSignature) rather than a bad read.
+func IsSignatureRejection(reason string) bool {
+	return reason == ErrUnsigned.Error() || reason == ErrBadSignature.Error()
+}
+
+// OIDValue is a raw SNMP value a counter was read from.
+type OIDValue struct {
+	OID   string `json:"oid"`
+	Type  string `json:"type"`
+	Value string `json:"value"`
+}
+
+// Confirmation describes how the device identified itself at read time.
+type Confirmation struct {
+	ReportedSerial string `json:"reported_serial,omitempty"` // Serial number read back from the device
+	SerialMatch    bool   `json:"serial_match"`              // ReportedSerial equals the expected serial
+	SysObjectID    string `json:"sys_object_id,omitempty"`
+	SysDescr       string `json:"sys_descr,omitempty"`
+	SysUpTime      int64  `json:"sys_uptime,omitempty"` // Hundredths of a second since the device's SNMP agent started
+	MACAddress     string `json:"mac_address,omitempty"`
+}
+
+// Read is a single certified meter read. Fields other than ID, Signature and
+// UploadedAt are covered by the signature and must never change after
+// capture.
+type Read struct {
+	ID           string       `json:"id"`
+	Serial       string       `json:"serial"`
+	AgentID      string       `json:"agent_id"`
+	IP           string       `json:"ip"`
+	Manufacturer string       `json:"manufacturer,omitempty"`
+	Model        string       `json:"model,omitempty"`
+	Period       string       `json:"period"` // Billing month the read belongs to (YYYY-MM, UTC)
+	Trigger      string       `json:"trigger"`
+	ReadAt       time.Time    `json:"read_at"`
+	PageCount    int64        `json:"page_count"`
+	MonoPages    int64        `json:"mono_pages"`
+	ColorPages   int64        `json:"color_pages"`
+	ScanCount    int64        `json:"scan_count,omitempty"`
+	Confirmation Confirmation `json:"confirmation"`
+	RawOIDs      []OIDValue   `json:"raw_oids"`
+
+	SignatureAlg string    `json:"signature_alg,omitempty"`
+	KeyID        string    `json:"key_id,omitempty"` // Signing key, see KeyID
+	Signature    string    `json:"signature,omitempty"`
+	UploadedAt   time.Time `json:"uploaded_at,omitzero"`
+
+	// Set by the agent when the server refused the read's signature; the
+	// read is kept but no longer uploaded.
+	QuarantinedAt    time.Time `json:"quarantined_at,omitzero"`
+	QuarantineReason string    `json:"quarantine_reason,omitempty"`
+}
+
+// signedFields is the canonical, signed view of a Read. Field order is fixed
+// by the struct, so the JSON encoding is deterministic.
+type signedFields struct {
+	Serial       string       `json:"serial"`
+	AgentID      string       `json:"agent_id"`
+	IP           string       `json:"ip"`
+	Manufacturer string       `json:"manufacturer"`
+	Model        string       `json:"model"`
+	Period       string       `json:"period"`
+	Trigger      string       `json:"trigger"`
+	ReadAt       string       `json:"read_at"`
+	PageCount    int64        `json:"page_count"`
+	MonoPages    int64        `json:"mono_pages"`
+	ColorPages   int64        `json:"color_pages"`
+	ScanCount    int64        `json:"scan_count"`
+	Confirmation Confirmation `json:"confirmation"`
+	RawOIDs      []OIDValue   `json:"raw_oids"`
+}
+
+// Canonical returns the bytes covered by the signature.
+func (r *Read) Canonical() []byte {
+	b, _ := json.Marshal(signedFields{
+		Serial:       r.Serial,
+		AgentID:      r.AgentID,
+		IP:           r.IP,
+		Manufacturer: r.Manufacturer,
+		Model:        r.Model,
+		Period:       r.Period,
+		Trigger:      r.Trigger,
+		ReadAt:       r.ReadAt.UTC().Format(time.RFC3339Nano),
+		PageCount:    r.PageCount,
+		MonoPages:    r.MonoPages,
+		ColorPages:   r.ColorPages,
+		ScanCount:    r.ScanCount,
+		Confirmation: r.Confirmation,
+		RawOIDs:      r.RawOIDs,
+	})
+	return b
+}
+
+// KeyID returns the short identifier of a signing public key, recorded on
+// each read so the server can pick the key among those the agent registered.
+func KeyID(pub ed25519.PublicKey) string {
+	sum := sha256.Sum256(pub)
+	return hex.EncodeToString(sum[:8])
+}
+
+// EncodePublicKey returns the form in which agents register a public key.
+func EncodePublicKey(pub ed25519.PublicKey) string {
+	return base64.StdEncoding.EncodeToString(pub)
+}
+
+// ParsePublicKey decodes a public key produced by EncodePublicKey.
+func ParsePublicKey(s string) (ed25519.PublicKey, error) {
+	b, err := base64.StdEncoding.DecodeString(s)
+	if err != nil || len(b) != ed25519.PublicKeySize {
+		return nil, fmt.Errorf("invalid meter read public key")
+	}
+	return ed25519.PublicKey(b), nil
+}
+
+// Sign signs the read with the agent's private key.
+func (r *Read) Sign(key ed25519.PrivateKey) {
+	r.SignatureAlg = SignatureAlgorithm
+	r.KeyID = KeyID(key.Public().(ed25519.PublicKey))
+	r.Signature = base64.StdEncoding.EncodeToString(ed25519.Sign(key, r.Canonical()))
+}
+
+// Verify checks the read's signature against pub.
+func (r *Read) Verify(pub ed25519.PublicKey) error {
+	if r.Signature == "" {
+		return ErrUnsigned
+	}
+	if r.SignatureAlg != SignatureAlgorithm || len(pub) != ed25519.PublicKeySize {
+		return ErrBadSignature
+	}
+	sig, err := base64.StdEncoding.DecodeString(r.Signature)
+	if err != nil || !ed25519.Verify(pub, r.Canonical(), sig) {
+		return ErrBadSignature
+	}
+	return nil
+}
+
+// PeriodOf returns the billing month ("YYYY-MM", UTC) containing t.
+func PeriodOf(t time.Time) string {
+	return t.UTC().Format("2006-01")
+}
+
+// Due reports whether the monthly read for the period containing now is due:
+// the configured day has been reached and no read exists for that period yet.
+// lastPeriod is the period of the device's latest scheduled read ("" if none).
+func Due(now time.Time, dayOfMonth int, lastPeriod string) bool {
+	if dayOfMonth < 1 {
+		dayOfMonth = 1
+	}
+	now = now.UTC()
+	if now.Day() < dayOfMonth {
+		return false
+	}
+	return lastPeriod != PeriodOf(now)
+}
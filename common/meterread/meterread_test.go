@@ -0,0 +1,98 @@
+package meterread
+
+import (
+	"crypto/ed25519"
+	"errors"
+	"testing"
+	"time"
+)
+
+func sampleRead() *Read {
+	return &Read{
+		ID:        "mr-1",
+		Serial:    "CN123",
+		AgentID:   "agent-1",
+		IP:        "10.0.0.5",
+		Period:    "2026-09",
+		Trigger:   TriggerScheduled,
+		ReadAt:    time.Date(2026, 9, 1, 2, 0, 0, 0, time.UTC),
+		PageCount: 12000,
+		MonoPages: 10000,
+		Confirmation: Confirmation{
+			ReportedSerial: "CN123",
+			SerialMatch:    true,
+		},
+		RawOIDs: []OIDValue{{OID: "1.3.6.1.2.1.43.10.2.1.4.1.1", Type: "Counter32", Value: "12000"}},
+	}
+}
+
+func TestSignVerify(t *testing.T) {
+	t.Parallel()
+	pub, key, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := sampleRead()
+	r.Sign(key)
+	if r.SignatureAlg != SignatureAlgorithm || r.Signature == "" || r.KeyID != KeyID(pub) {
+		t.Fatalf("Sign did not set signature: %+v", r)
+	}
+	if err := r.Verify(pub); err != nil {
+		t.Fatalf("Verify: %v", err)
+	}
+
+	// Upload bookkeeping is not signed
+	r.UploadedAt = time.Now()
+	if err := r.Verify(pub); err != nil {
+		t.Errorf("Verify after upload mark: %v", err)
+	}
+
+	other, _, _ := ed25519.GenerateKey(nil)
+	if err := r.Verify(other); !errors.Is(err, ErrBadSignature) {
+		t.Errorf("Verify with wrong key = %v, want ErrBadSignature", err)
+	}
+	r.PageCount++
+	if err := r.Verify(pub); !errors.Is(err, ErrBadSignature) {
+		t.Errorf("Verify after tamper = %v, want ErrBadSignature", err)
+	}
+	if err := sampleRead().Verify(pub); !errors.Is(err, ErrUnsigned) {
+		t.Errorf("Verify unsigned = %v, want ErrUnsigned", err)
+	}
+}
+
+func TestPublicKeyEncoding(t *testing.T) {
+	t.Parallel()
+	pub, _, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := ParsePublicKey(EncodePublicKey(pub))
+	if err != nil || !got.Equal(pub) {
+		t.Fatalf("round trip = %x, %v", got, err)
+	}
+	if _, err := ParsePublicKey("c2hvcnQ="); err == nil {
+		t.Error("short key accepted")
+	}
+}
+
+func TestDue(t *testing.T) {
+	t.Parallel()
+	now := time.Date(2026, 10, 5, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		day        int
+		lastPeriod string
+		want       bool
+	}{
+		{1, "", true},
+		{1, "2026-09", true},
+		{1, "2026-10", false},
+		{6, "2026-09", false}, // read day not reached yet
+		{5, "2026-09", true},
+		{0, "", true},
+	}
+	for _, tt := range tests {
+		if got := Due(now, tt.day, tt.lastPeriod); got != tt.want {
+			t.Errorf("Due(day=%d, last=%q) = %v, want %v", tt.day, tt.lastPeriod, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,454 @@
+// Package parquet writes and reads flat Parquet files for data exports and
+// archives. Every column is OPTIONAL and PLAIN encoded, pages are stored
+// uncompressed or GZIP compressed, which keeps the code small while remaining
+// readable by pandas, DuckDB, Spark and friends. Rows are buffered and flushed
+// as a row group every RowGroupSize rows, so large exports stream with bounded
+// memory.
+package parquet
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"time"
+)
+
+// Type is the logical type of a column.
+type Type int
+
+const (
+	Int64     Type = iota // INT64
+	Double                // DOUBLE
+	String                // BYTE_ARRAY annotated as UTF-8
+	Timestamp             // INT64 milliseconds since the Unix epoch, UTC
+)
+
+// Column describes one column of the flat schema.
+type Column struct {
+	Name string
+	Type Type
+}
+
+// Codec is the compression applied to data pages. Values match the Parquet
+// CompressionCodec enum.
+type Codec int32
+
+const (
+	Uncompressed Codec = 0
+	Gzip         Codec = 2
+)
+
+// DefaultRowGroupSize is the number of rows buffered before a row group is written.
+const DefaultRowGroupSize = 8192
+
+// ErrClosed is returned when writing to a closed Writer.
+var ErrClosed = errors.New("parquet: writer closed")
+
+const magic = "PAR1"
+
+// Parquet enum values used in metadata.
+const (
+	physicalInt64     = 2
+	physicalDouble    = 5
+	physicalByteArray = 6
+
+	repetitionOptional = 1
+
+	convertedUTF8            = 0
+	convertedTimestampMillis = 9
+
+	encodingPlain = 0
+	encodingRLE   = 3
+
+	pageTypeData = 0
+)
+
+type columnBuffer struct {
+	defined []bool
+	values  []byte
+}
+
+type chunkMeta struct {
+	offset       int64
+	size         int64 // bytes in the file
+	uncompressed int64
+	numValues    int64
+}
+
+type rowGroupMeta struct {
+	numRows int64
+	size    int64
+	chunks  []chunkMeta
+}
+
+// Writer streams rows into a Parquet file.
+type Writer struct {
+	// RowGroupSize overrides DefaultRowGroupSize when set before the first Write.
+	RowGroupSize int
+	// CreatedBy is recorded in the file footer.
+	CreatedBy string
+	// Compression is applied to every data page.
+	Compression Codec
+	// Metadata is stored as key/value metadata in the file footer.
+	Metadata map[string]string
+
+	w       io.Writer
+	cols    []Column
+	buffers []columnBuffer
+	marks   []int
+	rows    int
+	groups  []rowGroupMeta
+	offset  int64
+	total   int64
+	started bool
+	closed  bool
+	err     error
+}
+
+// NewWriter returns a Writer for the given columns. Close must be called to
+// write the footer; the underlying writer is not closed.
+func NewWriter(w io.Writer, cols []Column) *Writer {
+	return &Writer{
+		w:       w,
+		cols:    append([]Column(nil), cols...),
+		buffers: make([]columnBuffer, len(cols)),
+		marks:   make([]int, len(cols)),
+	}
+}
+
+// Write appends one row. values must have one entry per column; nil (or a
+// zero time.Time for Timestamp columns) is stored as null.
+func (pw *Writer) Write(values []any) error {
+	if pw.closed {
+		return ErrClosed
+	}
+	if pw.err != nil {
+		return pw.err
+	}
+	if len(values) != len(pw.cols) {
+		return fmt.Errorf("parquet: row has %d values, schema has %d columns", len(values), len(pw.cols))
+	}
+	for i, v := range values {
+		pw.marks[i] = len(pw.buffers[i].values)
+		if err := pw.buffers[i].append(pw.cols[i], v); err != nil {
+			// Drop the partial row so the columns stay aligned.
+			for j := 0; j < i; j++ {
+				pw.buffers[j].defined = pw.buffers[j].defined[:pw.rows]
+				pw.buffers[j].values = pw.buffers[j].values[:pw.marks[j]]
+			}
+			return err
+		}
+	}
+	pw.rows++
+	size := pw.RowGroupSize
+	if size <= 0 {
+		size = DefaultRowGroupSize
+	}
+	if pw.rows >= size {
+		return pw.flush()
+	}
+	return nil
+}
+
+// Close flushes buffered rows and writes the file footer.
+func (pw *Writer) Close() error {
+	if pw.closed {
+		return pw.err
+	}
+	if pw.err == nil && pw.rows > 0 {
+		pw.flush()
+	}
+	pw.closed = true
+	if pw.err != nil {
+		return pw.err
+	}
+	if err := pw.start(); err != nil {
+		return err
+	}
+	footer := pw.footer()
+	var trailer [4]byte
+	binary.LittleEndian.PutUint32(trailer[:], uint32(len(footer)))
+	footer = append(footer, trailer[:]...)
+	footer = append(footer, magic...)
+	return pw.write(footer)
+}
+
+func (b *columnBuffer) append(col Column, v any) error {
+	if v == nil {
+		b.defined = append(b.defined, false)
+		return nil
+	}
+	switch col.Type {
+	case Int64:
+		var n int64
+		switch x := v.(type) {
+		case int:
+			n = int64(x)
+		case int32:
+			n = int64(x)
+		case int64:
+			n = x
+		default:
+			return typeError(col, v)
+		}
+		b.values = binary.LittleEndian.AppendUint64(b.values, uint64(n))
+	case Double:
+		var f float64
+		switch x := v.(type) {
+		case float64:
+			f = x
+		case float32:
+			f = float64(x)
+		case int:
+			f = float64(x)
+		case int64:
+			f = float64(x)
+		default:
+			return typeError(col, v)
+		}
+		b.values = binary.LittleEndian.AppendUint64(b.values, math.Float64bits(f))
+	case String:
+		s, ok := v.(string)
+		if !ok {
+			return typeError(col, v)
+		}
+		b.values = binary.LittleEndian.AppendUint32(b.values, uint32(len(s)))
+		b.values = append(b.values, s...)
+	case Timestamp:
+		t, ok := v.(time.Time)
+		if !ok {
+			return typeError(col, v)
+		}
+		if t.IsZero() {
+			b.defined = append(b.defined, false)
+			return nil
+		}
+		b.values = binary.LittleEndian.AppendUint64(b.values, uint64(t.UnixMilli()))
+	default:
+		return fmt.Errorf("parquet: column %q has unknown type %d", col.Name, col.Type)
+	}
+	b.defined = append(b.defined, true)
+	return nil
+}
+
+func typeError(col Column, v any) error {
+	return fmt.Errorf("parquet: column %q cannot hold %T", col.Name, v)
+}
+
+func (pw *Writer) start() error {
+	if pw.started {
+		return nil
+	}
+	pw.started = true
+	return pw.write([]byte(magic))
+}
+
+func (pw *Writer) write(p []byte) error {
+	if pw.err != nil {
+		return pw.err
+	}
+	n, err := pw.w.Write(p)
+	pw.offset += int64(n)
+	if err != nil {
+		pw.err = err
+	}
+	return err
+}
+
+// flush writes the buffered rows as one row group with a single data page per column.
+func (pw *Writer) flush() error {
+	if err := pw.start(); err != nil {
+		return err
+	}
+	group := rowGroupMeta{numRows: int64(pw.rows)}
+	for i := range pw.buffers {
+		buf := &pw.buffers[i]
+		levels := encodeLevels(buf.defined)
+		page := make([]byte, 0, 4+len(levels)+len(buf.values))
+		page = binary.LittleEndian.AppendUint32(page, uint32(len(levels)))
+		page = append(page, levels...)
+		page = append(page, buf.values...)
+
+		stored := page
+		switch pw.Compression {
+		case Uncompressed:
+		case Gzip:
+			var err error
+			if stored, err = gzipPage(page); err != nil {
+				pw.err = err
+				return err
+			}
+		default:
+			pw.err = fmt.Errorf("parquet: unsupported compression %d", pw.Compression)
+			return pw.err
+		}
+		header := pageHeader(len(buf.defined), len(page), len(stored))
+		chunk := chunkMeta{
+			offset:       pw.offset,
+			size:         int64(len(header) + len(stored)),
+			uncompressed: int64(len(header) + len(page)),
+			numValues:    int64(len(buf.defined)),
+		}
+		if err := pw.write(header); err != nil {
+			return err
+		}
+		if err := pw.write(stored); err != nil {
+			return err
+		}
+		group.chunks = append(group.chunks, chunk)
+		group.size += chunk.uncompressed
+		buf.defined = buf.defined[:0]
+		buf.values = buf.values[:0]
+	}
+	pw.groups = append(pw.groups, group)
+	pw.total += group.numRows
+	pw.rows = 0
+	return nil
+}
+
+// encodeLevels encodes definition levels (bit width 1) as a single
+// bit-packed run of the RLE/bit-packing hybrid encoding.
+func encodeLevels(defined []bool) []byte {
+	groups := (len(defined) + 7) / 8
+	out := binary.AppendUvarint(nil, uint64(groups)<<1|1)
+	packed := make([]byte, groups)
+	for i, ok := range defined {
+		if ok {
+			packed[i/8] |= 1 << (i % 8)
+		}
+	}
+	return append(out, packed...)
+}
+
+func gzipPage(page []byte) ([]byte, error) {
+	var out bytes.Buffer
+	zw := gzip.NewWriter(&out)
+	if _, err := zw.Write(page); err != nil {
+		return nil, err
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return out.Bytes(), nil
+}
+
+func pageHeader(numValues, uncompressed, compressed int) []byte {
+	var c compactWriter
+	c.structBegin()
+	c.i32(1, pageTypeData)
+	c.i32(2, int32(uncompressed))
+	c.i32(3, int32(compressed))
+	c.fieldStruct(5) // data_page_header
+	c.i32(1, int32(numValues))
+	c.i32(2, encodingPlain)
+	c.i32(3, encodingRLE)
+	c.i32(4, encodingRLE)
+	c.structEnd()
+	c.structEnd()
+	return c.buf
+}
+
+func (pw *Writer) footer() []byte {
+	var c compactWriter
+	c.structBegin()
+	c.i32(1, 1) // version
+
+	c.listBegin(2, ctStruct, len(pw.cols)+1)
+	c.structBegin()
+	c.str(4, "schema")
+	c.i32(5, int32(len(pw.cols)))
+	c.structEnd()
+	for _, col := range pw.cols {
+		c.structBegin()
+		c.i32(1, physicalType(col.Type))
+		c.i32(3, repetitionOptional)
+		c.str(4, col.Name)
+		switch col.Type {
+		case String:
+			c.i32(6, convertedUTF8)
+			c.fieldStruct(10) // logicalType
+			c.fieldStruct(1)  // STRING
+			c.structEnd()
+			c.structEnd()
+		case Timestamp:
+			c.i32(6, convertedTimestampMillis)
+			c.fieldStruct(10) // logicalType
+			c.fieldStruct(8)  // TIMESTAMP
+			c.fieldHeader(1, ctBoolTrue)
+			c.fieldStruct(2) // unit
+			c.fieldStruct(1) // MILLIS
+			c.structEnd()
+			c.structEnd()
+			c.structEnd()
+			c.structEnd()
+		}
+		c.structEnd()
+	}
+
+	c.i64(3, pw.total)
+
+	c.listBegin(4, ctStruct, len(pw.groups))
+	for _, g := range pw.groups {
+		c.structBegin()
+		c.listBegin(1, ctStruct, len(g.chunks))
+		for i, chunk := range g.chunks {
+			col := pw.cols[i]
+			c.structBegin()
+			c.i64(2, chunk.offset)
+			c.fieldStruct(3) // meta_data
+			c.i32(1, physicalType(col.Type))
+			c.listBegin(2, ctI32, 2)
+			c.elemI32(encodingPlain)
+			c.elemI32(encodingRLE)
+			c.listBegin(3, ctBinary, 1)
+			c.elemString(col.Name)
+			c.i32(4, int32(pw.Compression))
+			c.i64(5, chunk.numValues)
+			c.i64(6, chunk.uncompressed)
+			c.i64(7, chunk.size)
+			c.i64(9, chunk.offset)
+			c.structEnd()
+			c.structEnd()
+		}
+		c.i64(2, g.size)
+		c.i64(3, g.numRows)
+		c.structEnd()
+	}
+
+	if len(pw.Metadata) > 0 {
+		keys := make([]string, 0, len(pw.Metadata))
+		for k := range pw.Metadata {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		c.listBegin(5, ctStruct, len(keys))
+		for _, k := range keys {
+			c.structBegin()
+			c.str(1, k)
+			c.str(2, pw.Metadata[k])
+			c.structEnd()
+		}
+	}
+
+	if pw.CreatedBy != "" {
+		c.str(6, pw.CreatedBy)
+	}
+	c.structEnd()
+	return c.buf
+}
+
+func physicalType(t Type) int32 {
+	switch t {
+	case Double:
+		return physicalDouble
+	case String:
+		return physicalByteArray
+	default:
+		return physicalInt64
+	}
+}
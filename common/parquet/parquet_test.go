@@ -0,0 +1,287 @@
+package parquet
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"testing"
+	"time"
+)
+
+// compactReader decodes thrift compact structs into map[fieldID]value so the
+// tests can check the footer independently of the writer.
+type compactReader struct {
+	data []byte
+	pos  int
+}
+
+func (r *compactReader) byte() byte {
+	b := r.data[r.pos]
+	r.pos++
+	return b
+}
+
+func (r *compactReader) uvarint() uint64 {
+	v, n := binary.Uvarint(r.data[r.pos:])
+	r.pos += n
+	return v
+}
+
+func (r *compactReader) zigzag() int64 {
+	v := r.uvarint()
+	return int64(v>>1) ^ -int64(v&1)
+}
+
+func (r *compactReader) value(typ byte) any {
+	switch typ {
+	case ctBoolTrue:
+		return true
+	case 2:
+		return false
+	case ctI32, ctI64:
+		return r.zigzag()
+	case ctBinary:
+		n := int(r.uvarint())
+		s := string(r.data[r.pos : r.pos+n])
+		r.pos += n
+		return s
+	case ctList:
+		h := r.byte()
+		size, elem := int(h>>4), h&0x0f
+		if size == 15 {
+			size = int(r.uvarint())
+		}
+		out := make([]any, size)
+		for i := range out {
+			out[i] = r.value(elem)
+		}
+		return out
+	case ctStruct:
+		return r.readStruct()
+	}
+	panic(fmt.Sprintf("unsupported compact type %d", typ))
+}
+
+func (r *compactReader) readStruct() map[int16]any {
+	fields := map[int16]any{}
+	var last int16
+	for {
+		h := r.byte()
+		if h == 0 {
+			return fields
+		}
+		typ := h & 0x0f
+		id := last + int16(h>>4)
+		if h>>4 == 0 {
+			id = int16(r.zigzag())
+		}
+		fields[id] = r.value(typ)
+		last = id
+	}
+}
+
+func TestWriterRoundTrip(t *testing.T) {
+	t.Parallel()
+	cols := []Column{
+		{Name: "timestamp", Type: Timestamp},
+		{Name: "tier", Type: String},
+		{Name: "page_count", Type: Int64},
+		{Name: "toner_black", Type: Double},
+	}
+	var buf bytes.Buffer
+	w := NewWriter(&buf, cols)
+	w.RowGroupSize = 2
+	w.CreatedBy = "printmaster test"
+	base := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	if err := w.Write([]any{base, "raw", 100, 80.5}); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	// A rejected row must not leave partial values behind.
+	if err := w.Write([]any{base, "raw", "100", nil}); err == nil {
+		t.Fatal("expected error for mistyped value")
+	}
+	rows := [][]any{
+		{base.Add(time.Hour), "raw", nil, nil},
+		{base.Add(2 * time.Hour), "hourly", int64(130), 79},
+	}
+	for _, row := range rows {
+		if err := w.Write(row); err != nil {
+			t.Fatalf("Write: %v", err)
+		}
+	}
+	if err := w.Write([]any{base}); err == nil {
+		t.Fatal("expected error for short row")
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	data := buf.Bytes()
+	if string(data[:4]) != magic || string(data[len(data)-4:]) != magic {
+		t.Fatal("missing PAR1 magic")
+	}
+	footerLen := int(binary.LittleEndian.Uint32(data[len(data)-8:]))
+	footerStart := len(data) - 8 - footerLen
+	meta := (&compactReader{data: data[footerStart : len(data)-8]}).readStruct()
+
+	if meta[3].(int64) != 3 || meta[6] != "printmaster test" {
+		t.Fatalf("unexpected footer %+v", meta)
+	}
+	schema := meta[2].([]any)
+	if len(schema) != 5 || schema[0].(map[int16]any)[5].(int64) != 4 {
+		t.Fatalf("unexpected schema %+v", schema)
+	}
+	if name := schema[2].(map[int16]any)[4]; name != "tier" {
+		t.Fatalf("schema[2] name = %v", name)
+	}
+	groups := meta[4].([]any)
+	if len(groups) != 2 {
+		t.Fatalf("expected 2 row groups, got %d", len(groups))
+	}
+
+	// Decode the page_count and toner columns of every row group.
+	var pages []any
+	var toner []any
+	for _, g := range groups {
+		chunks := g.(map[int16]any)[1].([]any)
+		for _, c := range chunks {
+			if nv := c.(map[int16]any)[3].(map[int16]any)[5]; nv != g.(map[int16]any)[3] {
+				t.Fatalf("column chunk has %v values, row group has %v rows", nv, g.(map[int16]any)[3])
+			}
+		}
+		for ci, col := range []int{2, 3} {
+			md := chunks[col].(map[int16]any)[3].(map[int16]any)
+			offset := int(md[9].(int64))
+			r := &compactReader{data: data, pos: offset}
+			header := r.readStruct()
+			dph := header[5].(map[int16]any)
+			n := int(dph[1].(int64))
+			page := data[r.pos : r.pos+int(header[2].(int64))]
+			if int64(r.pos-offset+len(page)) != md[6].(int64) {
+				t.Fatalf("chunk size mismatch")
+			}
+			levelsLen := int(binary.LittleEndian.Uint32(page))
+			lr := &compactReader{data: page[4 : 4+levelsLen]}
+			run := lr.uvarint()
+			if run&1 != 1 {
+				t.Fatal("expected bit-packed definition levels")
+			}
+			values := page[4+levelsLen:]
+			for i := 0; i < n; i++ {
+				var v any
+				if lr.data[lr.pos+i/8]&(1<<(i%8)) != 0 {
+					bits := binary.LittleEndian.Uint64(values)
+					values = values[8:]
+					if ci == 0 {
+						v = int64(bits)
+					} else {
+						v = math.Float64frombits(bits)
+					}
+				}
+				if ci == 0 {
+					pages = append(pages, v)
+				} else {
+					toner = append(toner, v)
+				}
+			}
+		}
+	}
+	if fmt.Sprint(pages) != "[100 <nil> 130]" || fmt.Sprint(toner) != "[80.5 <nil> 79]" {
+		t.Fatalf("decoded pages=%v toner=%v", pages, toner)
+	}
+}
+
+func TestWriterEmpty(t *testing.T) {
+	t.Parallel()
+	var buf bytes.Buffer
+	w := NewWriter(&buf, []Column{{Name: "a", Type: Int64}})
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	if err := w.Write([]any{int64(1)}); err != ErrClosed {
+		t.Fatalf("expected ErrClosed, got %v", err)
+	}
+	data := buf.Bytes()
+	footerLen := int(binary.LittleEndian.Uint32(data[len(data)-8:]))
+	meta := (&compactReader{data: data[len(data)-8-footerLen : len(data)-8]}).readStruct()
+	if meta[3].(int64) != 0 || len(meta[4].([]any)) != 0 {
+		t.Fatalf("unexpected empty footer %+v", meta)
+	}
+}
+
+func TestReadRoundTrip(t *testing.T) {
+	t.Parallel()
+	cols := []Column{
+		{Name: "serial", Type: String},
+		{Name: "timestamp", Type: Timestamp},
+		{Name: "page_count", Type: Int64},
+		{Name: "toner_black", Type: Double},
+	}
+	base := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	for _, codec := range []Codec{Uncompressed, Gzip} {
+		var buf bytes.Buffer
+		w := NewWriter(&buf, cols)
+		w.RowGroupSize = 7
+		w.Compression = codec
+		w.CreatedBy = "printmaster test"
+		w.Metadata = map[string]string{"period": "2026-03"}
+		var want [][]any
+		for i := 0; i < 20; i++ {
+			row := []any{fmt.Sprintf("SER%d", i%3), base.Add(time.Duration(i) * time.Minute), int64(100 + i), float64(i) / 2}
+			if i%4 == 0 {
+				row[2], row[3] = nil, nil
+			}
+			if err := w.Write(row); err != nil {
+				t.Fatalf("Write: %v", err)
+			}
+			want = append(want, row)
+		}
+		if err := w.Close(); err != nil {
+			t.Fatalf("Close: %v", err)
+		}
+
+		data := buf.Bytes()
+		f, err := Read(bytes.NewReader(data), int64(len(data)))
+		if err != nil {
+			t.Fatalf("codec %d: Read: %v", codec, err)
+		}
+		if fmt.Sprint(f.Columns) != fmt.Sprint(cols) || f.CreatedBy != "printmaster test" || f.Metadata["period"] != "2026-03" {
+			t.Fatalf("codec %d: columns=%v created_by=%q metadata=%v", codec, f.Columns, f.CreatedBy, f.Metadata)
+		}
+		if fmt.Sprint(f.Rows) != fmt.Sprint(want) {
+			t.Fatalf("codec %d: rows = %v, want %v", codec, f.Rows, want)
+		}
+
+		// A damaged footer length is reported, not decoded into garbage
+		bad := append([]byte(nil), data...)
+		bad[len(bad)-5] = 0x7f
+		if _, err := Read(bytes.NewReader(bad), int64(len(bad))); err == nil {
+			t.Errorf("codec %d: expected error for corrupt footer", codec)
+		}
+	}
+
+	var empty bytes.Buffer
+	if err := NewWriter(&empty, cols).Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	f, err := Read(bytes.NewReader(empty.Bytes()), int64(empty.Len()))
+	if err != nil || len(f.Rows) != 0 || len(f.Columns) != 4 {
+		t.Fatalf("empty file: %+v, %v", f, err)
+	}
+	if _, err := Read(bytes.NewReader([]byte("not parquet at all")), 18); err == nil {
+		t.Error("expected error for non-Parquet input")
+	}
+}
+
+func TestDecodeLevelsRLE(t *testing.T) {
+	t.Parallel()
+	// RLE run of five 1s, then a bit-packed group 0b00000101
+	levels, err := decodeLevels([]byte{5 << 1, 1, 1<<1 | 1, 0x05}, 8)
+	if err != nil {
+		t.Fatalf("decodeLevels: %v", err)
+	}
+	if fmt.Sprint(levels) != "[true true true true true true false true]" {
+		t.Fatalf("levels = %v", levels)
+	}
+}
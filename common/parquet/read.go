@@ -0,0 +1,303 @@
+package parquet
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"time"
+)
+
+// File is a decoded Parquet file.
+type File struct {
+	Columns   []Column
+	Rows      [][]any // int64, float64, string, time.Time (UTC) or nil per column
+	Metadata  map[string]string
+	CreatedBy string
+}
+
+// maxFooterSize bounds the footer read from untrusted files.
+const maxFooterSize = 64 << 20
+
+const (
+	repetitionRequired = 0
+
+	convertedTimestampMicros = 10
+
+	pageTypeDictionary = 2
+)
+
+// readColumn is a column as laid out in a file being read.
+type readColumn struct {
+	Column
+	optional bool
+	micros   bool // Timestamp stored in microseconds
+}
+
+// Read decodes a whole file. It reads what Writer produces and other flat
+// files that use PLAIN encoding, v1 data pages and no or GZIP compression;
+// nested schemas and dictionary encoding are rejected.
+func Read(r io.ReaderAt, size int64) (*File, error) {
+	if size < 12 {
+		return nil, errors.New("parquet: file too small")
+	}
+	var tail [8]byte
+	if _, err := r.ReadAt(tail[:], size-8); err != nil {
+		return nil, err
+	}
+	if string(tail[4:]) != magic {
+		return nil, errors.New("parquet: missing PAR1 magic")
+	}
+	footerLen := int64(binary.LittleEndian.Uint32(tail[:4]))
+	if footerLen > maxFooterSize || footerLen > size-12 {
+		return nil, errors.New("parquet: invalid footer length")
+	}
+	footer := make([]byte, footerLen)
+	if _, err := r.ReadAt(footer, size-8-footerLen); err != nil {
+		return nil, err
+	}
+	meta, err := readThriftStruct(bytes.NewReader(footer))
+	if err != nil {
+		return nil, fmt.Errorf("parquet: footer: %w", err)
+	}
+	cols, err := readSchema(meta.list(2))
+	if err != nil {
+		return nil, err
+	}
+
+	f := &File{Metadata: map[string]string{}, CreatedBy: meta.str(6)}
+	for _, c := range cols {
+		f.Columns = append(f.Columns, c.Column)
+	}
+	for _, v := range meta.list(5) {
+		if kv, ok := v.(thriftStruct); ok {
+			f.Metadata[kv.str(1)] = kv.str(2)
+		}
+	}
+
+	numRows := meta.int(3)
+	// Every row takes at least one bit in the file
+	if numRows < 0 || numRows > size*8 {
+		return nil, errors.New("parquet: invalid row count")
+	}
+	f.Rows = make([][]any, 0, numRows)
+	for _, g := range meta.list(4) {
+		group, _ := g.(thriftStruct)
+		groupRows := group.int(3)
+		chunks := group.list(1)
+		if groupRows < 0 || int64(len(f.Rows))+groupRows > numRows || len(chunks) != len(cols) {
+			return nil, errors.New("parquet: invalid row group")
+		}
+		rows := make([][]any, groupRows)
+		for i := range rows {
+			rows[i] = make([]any, len(cols))
+		}
+		for i, c := range chunks {
+			chunk, _ := c.(thriftStruct)
+			if err := readChunk(r, size, cols[i], i, chunk.strct(3), rows); err != nil {
+				return nil, fmt.Errorf("parquet: column %q: %w", cols[i].Name, err)
+			}
+		}
+		f.Rows = append(f.Rows, rows...)
+	}
+	if int64(len(f.Rows)) != numRows {
+		return nil, errors.New("parquet: row groups do not add up to the row count")
+	}
+	return f, nil
+}
+
+func readSchema(schema []any) ([]readColumn, error) {
+	if len(schema) == 0 {
+		return nil, errors.New("parquet: empty schema")
+	}
+	root, _ := schema[0].(thriftStruct)
+	if int(root.int(5)) != len(schema)-1 {
+		return nil, errors.New("parquet: nested schemas are not supported")
+	}
+	cols := make([]readColumn, 0, len(schema)-1)
+	for _, s := range schema[1:] {
+		el, _ := s.(thriftStruct)
+		if el.int(5) != 0 {
+			return nil, errors.New("parquet: nested schemas are not supported")
+		}
+		col := readColumn{Column: Column{Name: el.str(4)}}
+		switch el.int(3) {
+		case repetitionRequired:
+		case repetitionOptional:
+			col.optional = true
+		default:
+			return nil, fmt.Errorf("parquet: column %q is repeated", col.Name)
+		}
+		_, hasConverted := el[6]
+		converted := el.int(6)
+		switch el.int(1) {
+		case physicalInt64:
+			col.Type = Int64
+			if hasConverted && (converted == convertedTimestampMillis || converted == convertedTimestampMicros) {
+				col.Type = Timestamp
+				col.micros = converted == convertedTimestampMicros
+			}
+		case physicalDouble:
+			col.Type = Double
+		case physicalByteArray:
+			col.Type = String
+		default:
+			return nil, fmt.Errorf("parquet: column %q has unsupported type %d", col.Name, el.int(1))
+		}
+		cols = append(cols, col)
+	}
+	return cols, nil
+}
+
+// readChunk decodes one column chunk into rows[*][ci].
+func readChunk(r io.ReaderAt, size int64, col readColumn, ci int, md thriftStruct, rows [][]any) error {
+	codec := Codec(md.int(4))
+	if codec != Uncompressed && codec != Gzip {
+		return fmt.Errorf("unsupported compression %d", codec)
+	}
+	offset, length := md.int(9), md.int(7)
+	if offset < 4 || length < 0 || offset+length > size {
+		return errors.New("invalid chunk bounds")
+	}
+	data := make([]byte, length)
+	if _, err := r.ReadAt(data, offset); err != nil {
+		return err
+	}
+	br := bytes.NewReader(data)
+
+	read := 0
+	for read < len(rows) {
+		header, err := readThriftStruct(br)
+		if err != nil {
+			return fmt.Errorf("page header: %w", err)
+		}
+		stored, plain := header.int(3), header.int(2)
+		if stored < 0 || stored > int64(br.Len()) || plain < 0 || plain > maxFooterSize {
+			return errors.New("invalid page size")
+		}
+		page := make([]byte, stored)
+		if _, err := io.ReadFull(br, page); err != nil {
+			return err
+		}
+		switch header.int(1) {
+		case pageTypeData:
+		case pageTypeDictionary:
+			return errors.New("dictionary encoding is not supported")
+		default:
+			continue // index pages carry no values
+		}
+		dph := header.strct(5)
+		if dph.int(2) != encodingPlain {
+			return fmt.Errorf("unsupported encoding %d", dph.int(2))
+		}
+		n := int(dph.int(1))
+		if n < 0 || n > len(rows)-read {
+			return errors.New("page has more values than the row group")
+		}
+		if codec == Gzip {
+			zr, err := gzip.NewReader(bytes.NewReader(page))
+			if err != nil {
+				return err
+			}
+			if page, err = io.ReadAll(io.LimitReader(zr, plain)); err != nil {
+				return err
+			}
+		}
+		if err := decodePage(col, ci, page, rows[read:read+n]); err != nil {
+			return err
+		}
+		read += n
+	}
+	return nil
+}
+
+func decodePage(col readColumn, ci int, page []byte, rows [][]any) error {
+	defined := func(int) bool { return true }
+	if col.optional {
+		if len(page) < 4 {
+			return io.ErrUnexpectedEOF
+		}
+		n := binary.LittleEndian.Uint32(page)
+		if uint64(n) > uint64(len(page)-4) {
+			return io.ErrUnexpectedEOF
+		}
+		levels, err := decodeLevels(page[4:4+n], len(rows))
+		if err != nil {
+			return err
+		}
+		defined = func(i int) bool { return levels[i] }
+		page = page[4+n:]
+	}
+	for i := range rows {
+		if !defined(i) {
+			continue
+		}
+		switch col.Type {
+		case String:
+			if len(page) < 4 {
+				return io.ErrUnexpectedEOF
+			}
+			n := binary.LittleEndian.Uint32(page)
+			if uint64(n) > uint64(len(page)-4) {
+				return io.ErrUnexpectedEOF
+			}
+			rows[i][ci] = string(page[4 : 4+n])
+			page = page[4+n:]
+			continue
+		}
+		if len(page) < 8 {
+			return io.ErrUnexpectedEOF
+		}
+		bits := binary.LittleEndian.Uint64(page)
+		page = page[8:]
+		switch {
+		case col.Type == Double:
+			rows[i][ci] = math.Float64frombits(bits)
+		case col.Type == Timestamp && col.micros:
+			rows[i][ci] = time.UnixMicro(int64(bits)).UTC()
+		case col.Type == Timestamp:
+			rows[i][ci] = time.UnixMilli(int64(bits)).UTC()
+		default:
+			rows[i][ci] = int64(bits)
+		}
+	}
+	return nil
+}
+
+// decodeLevels decodes n definition levels of bit width 1 from the
+// RLE/bit-packing hybrid encoding.
+func decodeLevels(data []byte, n int) ([]bool, error) {
+	out := make([]bool, 0, n)
+	for len(out) < n {
+		h, k := binary.Uvarint(data)
+		if k <= 0 {
+			return nil, errors.New("invalid definition levels")
+		}
+		data = data[k:]
+		if h&1 == 1 {
+			// Bit-packed run of 8-value groups
+			groups := h >> 1
+			if groups > uint64(len(data)) {
+				return nil, io.ErrUnexpectedEOF
+			}
+			for i := 0; i < int(groups)*8 && len(out) < n; i++ {
+				out = append(out, data[i/8]&(1<<(i%8)) != 0)
+			}
+			data = data[groups:]
+			continue
+		}
+		// RLE run: the repeated value takes one byte at bit width 1
+		if len(data) == 0 {
+			return nil, io.ErrUnexpectedEOF
+		}
+		v := data[0]&1 == 1
+		data = data[1:]
+		for count := h >> 1; count > 0 && len(out) < n; count-- {
+			out = append(out, v)
+		}
+	}
+	return out, nil
+}
@@ -0,0 +1,257 @@
+package parquet
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// Thrift compact protocol type ids used by the Parquet footer and page headers.
+const (
+	ctStop      = 0
+	ctBoolTrue  = 1
+	ctBoolFalse = 2
+	ctByte      = 3
+	ctI16       = 4
+	ctI32       = 5
+	ctI64       = 6
+	ctDouble    = 7
+	ctBinary    = 8
+	ctList      = 9
+	ctSet       = 10
+	ctMap       = 11
+	ctStruct    = 12
+)
+
+// compactWriter encodes thrift structs with the compact protocol. Only the
+// subset needed for Parquet metadata is implemented.
+type compactWriter struct {
+	buf    []byte
+	lastID []int16
+}
+
+func (c *compactWriter) structBegin() {
+	c.lastID = append(c.lastID, 0)
+}
+
+func (c *compactWriter) structEnd() {
+	c.buf = append(c.buf, 0) // STOP
+	c.lastID = c.lastID[:len(c.lastID)-1]
+}
+
+func (c *compactWriter) fieldHeader(id int16, typ byte) {
+	last := &c.lastID[len(c.lastID)-1]
+	if delta := id - *last; delta > 0 && delta <= 15 {
+		c.buf = append(c.buf, byte(delta)<<4|typ)
+	} else {
+		c.buf = append(c.buf, typ)
+		c.varint(zigzag(int64(id)))
+	}
+	*last = id
+}
+
+func (c *compactWriter) varint(v uint64) {
+	c.buf = binary.AppendUvarint(c.buf, v)
+}
+
+func zigzag(v int64) uint64 {
+	return uint64((v << 1) ^ (v >> 63))
+}
+
+func (c *compactWriter) i32(id int16, v int32) {
+	c.fieldHeader(id, ctI32)
+	c.varint(zigzag(int64(v)))
+}
+
+func (c *compactWriter) i64(id int16, v int64) {
+	c.fieldHeader(id, ctI64)
+	c.varint(zigzag(v))
+}
+
+func (c *compactWriter) str(id int16, s string) {
+	c.fieldHeader(id, ctBinary)
+	c.varint(uint64(len(s)))
+	c.buf = append(c.buf, s...)
+}
+
+func (c *compactWriter) fieldStruct(id int16) {
+	c.fieldHeader(id, ctStruct)
+	c.structBegin()
+}
+
+func (c *compactWriter) listBegin(id int16, elemType byte, size int) {
+	c.fieldHeader(id, ctList)
+	if size < 15 {
+		c.buf = append(c.buf, byte(size)<<4|elemType)
+	} else {
+		c.buf = append(c.buf, 0xf0|elemType)
+		c.varint(uint64(size))
+	}
+}
+
+// Element writers for list members (no field header).
+
+func (c *compactWriter) elemI32(v int32) {
+	c.varint(zigzag(int64(v)))
+}
+
+func (c *compactWriter) elemString(s string) {
+	c.varint(uint64(len(s)))
+	c.buf = append(c.buf, s...)
+}
+
+// thriftStruct is a decoded struct: field id to value. Values are int64 (all
+// integer types), bool, []byte, []any (lists and sets) or thriftStruct.
+// Doubles are skipped and maps are rejected; Parquet metadata uses neither.
+type thriftStruct map[int16]any
+
+func (s thriftStruct) int(id int16) int64 {
+	v, _ := s[id].(int64)
+	return v
+}
+
+func (s thriftStruct) str(id int16) string {
+	v, _ := s[id].([]byte)
+	return string(v)
+}
+
+func (s thriftStruct) strct(id int16) thriftStruct {
+	v, _ := s[id].(thriftStruct)
+	return v
+}
+
+func (s thriftStruct) list(id int16) []any {
+	v, _ := s[id].([]any)
+	return v
+}
+
+// maxThriftDepth bounds nesting when decoding untrusted files.
+const maxThriftDepth = 16
+
+var errThriftLength = errors.New("parquet: invalid thrift length")
+
+// thriftReader decodes compact protocol structs from untrusted input; every
+// length is checked against the bytes left before allocating.
+type thriftReader struct {
+	r *bytes.Reader
+}
+
+// readThriftStruct decodes one struct, leaving r positioned after it.
+func readThriftStruct(r *bytes.Reader) (thriftStruct, error) {
+	return (&thriftReader{r: r}).readStruct(0)
+}
+
+func (t *thriftReader) uvarint() (uint64, error) {
+	return binary.ReadUvarint(t.r)
+}
+
+func (t *thriftReader) varint() (int64, error) {
+	u, err := t.uvarint()
+	return int64(u>>1) ^ -int64(u&1), err
+}
+
+func (t *thriftReader) readBytes() ([]byte, error) {
+	n, err := t.uvarint()
+	if err != nil {
+		return nil, err
+	}
+	if n > uint64(t.r.Len()) {
+		return nil, errThriftLength
+	}
+	b := make([]byte, n)
+	_, err = io.ReadFull(t.r, b)
+	return b, err
+}
+
+func (t *thriftReader) readStruct(depth int) (thriftStruct, error) {
+	if depth > maxThriftDepth {
+		return nil, errors.New("parquet: thrift nesting too deep")
+	}
+	s := thriftStruct{}
+	var last int16
+	for {
+		h, err := t.r.ReadByte()
+		if err != nil {
+			return nil, err
+		}
+		typ := h & 0x0f
+		if typ == ctStop {
+			return s, nil
+		}
+		id := last + int16(h>>4)
+		if h>>4 == 0 {
+			v, err := t.varint()
+			if err != nil {
+				return nil, err
+			}
+			id = int16(v)
+		}
+		last = id
+		v, err := t.readValue(typ, depth)
+		if err != nil {
+			return nil, fmt.Errorf("field %d: %w", id, err)
+		}
+		if v != nil {
+			s[id] = v
+		}
+	}
+}
+
+func (t *thriftReader) readValue(typ byte, depth int) (any, error) {
+	switch typ {
+	case ctBoolTrue:
+		return true, nil
+	case ctBoolFalse:
+		return false, nil
+	case ctByte:
+		b, err := t.r.ReadByte()
+		return int64(int8(b)), err
+	case ctI16, ctI32, ctI64:
+		return t.varint()
+	case ctDouble:
+		_, err := t.r.Seek(8, io.SeekCurrent)
+		return nil, err
+	case ctBinary:
+		return t.readBytes()
+	case ctList, ctSet:
+		h, err := t.r.ReadByte()
+		if err != nil {
+			return nil, err
+		}
+		n, elem := uint64(h>>4), h&0x0f
+		if n == 15 {
+			if n, err = t.uvarint(); err != nil {
+				return nil, err
+			}
+		}
+		// Every element takes at least one byte
+		if n > uint64(t.r.Len()) {
+			return nil, errThriftLength
+		}
+		out := make([]any, 0, n)
+		for i := uint64(0); i < n; i++ {
+			// Booleans in lists are a whole byte each
+			if elem == ctBoolTrue || elem == ctBoolFalse {
+				b, err := t.r.ReadByte()
+				if err != nil {
+					return nil, err
+				}
+				out = append(out, b == ctBoolTrue)
+				continue
+			}
+			v, err := t.readValue(elem, depth+1)
+			if err != nil {
+				return nil, err
+			}
+			out = append(out, v)
+		}
+		return out, nil
+	case ctStruct:
+		return t.readStruct(depth + 1)
+	case ctMap:
+		return nil, errors.New("parquet: thrift maps are not supported")
+	}
+	return nil, fmt.Errorf("parquet: unknown thrift type %d", typ)
+}
@@ -0,0 +1,153 @@
+// Package promexport writes metrics in the Prometheus text exposition format
+// (version 0.0.4) or as OpenMetrics 1.0, so Prometheus can scrape the agent
+// and server directly. It only formats metric families; collecting the values
+// is up to the caller.
+package promexport
+
+import (
+	"bufio"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Content types of the two formats.
+const (
+	ContentType            = "text/plain; version=0.0.4; charset=utf-8"
+	ContentTypeOpenMetrics = "application/openmetrics-text; version=1.0.0; charset=utf-8"
+)
+
+// WantsOpenMetrics reports whether an Accept header asks for OpenMetrics.
+func WantsOpenMetrics(accept string) bool {
+	return strings.Contains(accept, "application/openmetrics-text")
+}
+
+// Type is a metric family type.
+type Type string
+
+// Metric types.
+const (
+	Gauge   Type = "gauge"
+	Counter Type = "counter"
+)
+
+// Label is one label of a sample.
+type Label struct {
+	Name  string
+	Value string
+}
+
+// Sample is one value of a family.
+type Sample struct {
+	Labels []Label
+	Value  float64
+}
+
+// Family is a named metric with its samples.
+type Family struct {
+	Name    string
+	Help    string
+	Type    Type
+	Samples []Sample
+}
+
+// NewGauge returns an empty gauge family.
+func NewGauge(name, help string) *Family {
+	return &Family{Name: name, Help: help, Type: Gauge}
+}
+
+// NewCounter returns an empty counter family.
+func NewCounter(name, help string) *Family {
+	return &Family{Name: name, Help: help, Type: Counter}
+}
+
+// Add appends a sample. labels are name/value pairs; a trailing name without
+// a value is ignored.
+func (f *Family) Add(value float64, labels ...string) {
+	s := Sample{Value: value}
+	for i := 0; i+1 < len(labels); i += 2 {
+		s.Labels = append(s.Labels, Label{Name: labels[i], Value: labels[i+1]})
+	}
+	f.Samples = append(f.Samples, s)
+}
+
+// Write writes families in order in the text exposition format. Families
+// without samples are skipped.
+func Write(w io.Writer, families []*Family) error {
+	return write(w, families, false)
+}
+
+// WriteOpenMetrics writes families in order as OpenMetrics. Counter names
+// keep their _total suffix on samples; the family name drops it.
+func WriteOpenMetrics(w io.Writer, families []*Family) error {
+	return write(w, families, true)
+}
+
+func write(w io.Writer, families []*Family, openMetrics bool) error {
+	bw := bufio.NewWriter(w)
+	for _, f := range families {
+		if f == nil || len(f.Samples) == 0 {
+			continue
+		}
+		name := f.Name
+		if openMetrics && f.Type == Counter {
+			name = strings.TrimSuffix(name, "_total")
+		}
+		if f.Help != "" {
+			help := escapeHelp(f.Help)
+			if openMetrics {
+				help = strings.ReplaceAll(help, `"`, `\"`)
+			}
+			bw.WriteString("# HELP " + name + " " + help + "\n")
+		}
+		bw.WriteString("# TYPE " + name + " " + string(f.Type) + "\n")
+		for _, s := range f.Samples {
+			bw.WriteString(f.Name)
+			if len(s.Labels) > 0 {
+				bw.WriteByte('{')
+				for i, l := range s.Labels {
+					if i > 0 {
+						bw.WriteByte(',')
+					}
+					bw.WriteString(l.Name + `="` + escapeLabelValue(l.Value) + `"`)
+				}
+				bw.WriteByte('}')
+			}
+			bw.WriteString(" " + formatValue(s.Value) + "\n")
+		}
+	}
+	if openMetrics {
+		bw.WriteString("# EOF\n")
+	}
+	return bw.Flush()
+}
+
+var (
+	helpEscaper  = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+	labelEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+)
+
+func escapeHelp(s string) string {
+	return helpEscaper.Replace(s)
+}
+
+func escapeLabelValue(s string) string {
+	return labelEscaper.Replace(s)
+}
+
+func formatValue(v float64) string {
+	switch {
+	case math.IsNaN(v):
+		return "NaN"
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case v == math.Trunc(v) && math.Abs(v) < 1e15:
+		// Whole numbers (counts, Unix times) without an exponent
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	default:
+		return strconv.FormatFloat(v, 'g', -1, 64)
+	}
+}
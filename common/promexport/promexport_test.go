@@ -0,0 +1,58 @@
+package promexport
+
+import (
+	"math"
+	"strings"
+	"testing"
+)
+
+func TestWrite(t *testing.T) {
+	toner := NewGauge("printmaster_device_toner_level_percent", "Toner remaining.\nPercent.")
+	toner.Add(42, "serial", "SN1", "supply", "black")
+	toner.Add(7.5, "serial", `S"N\2`, "supply", "cyan")
+	scans := NewCounter("printmaster_agent_scans_total", "")
+	scans.Add(3)
+	scans.Add(math.NaN(), "status", "failed")
+	empty := NewGauge("printmaster_unused", "Never written")
+
+	var b strings.Builder
+	if err := Write(&b, []*Family{toner, empty, nil, scans}); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	want := `# HELP printmaster_device_toner_level_percent Toner remaining.\nPercent.
+# TYPE printmaster_device_toner_level_percent gauge
+printmaster_device_toner_level_percent{serial="SN1",supply="black"} 42
+printmaster_device_toner_level_percent{serial="S\"N\\2",supply="cyan"} 7.5
+# TYPE printmaster_agent_scans_total counter
+printmaster_agent_scans_total 3
+printmaster_agent_scans_total{status="failed"} NaN
+`
+	if b.String() != want {
+		t.Fatalf("unexpected output:\n%s\nwant:\n%s", b.String(), want)
+	}
+}
+
+func TestWriteOpenMetrics(t *testing.T) {
+	agents := NewCounter("printmaster_uploads_total", `Uploads by "kind".`)
+	agents.Add(2, "kind", "metrics")
+	up := NewGauge("printmaster_agent_up", "")
+	up.Add(1, "agent_id", "a1")
+
+	var b strings.Builder
+	if err := WriteOpenMetrics(&b, []*Family{agents, up}); err != nil {
+		t.Fatalf("WriteOpenMetrics: %v", err)
+	}
+	want := `# HELP printmaster_uploads Uploads by \"kind\".
+# TYPE printmaster_uploads counter
+printmaster_uploads_total{kind="metrics"} 2
+# TYPE printmaster_agent_up gauge
+printmaster_agent_up{agent_id="a1"} 1
+# EOF
+`
+	if b.String() != want {
+		t.Fatalf("unexpected output:\n%s\nwant:\n%s", b.String(), want)
+	}
+	if !WantsOpenMetrics("application/openmetrics-text;version=1.0.0,text/plain;q=0.5") || WantsOpenMetrics("text/plain") {
+		t.Fatal("unexpected content negotiation")
+	}
+}
@@ -0,0 +1,501 @@
+// Package qrcode is a small QR code encoder used for printable device asset
+// tags. It supports byte mode at error correction level M for versions 1-10
+// (up to 213 bytes), which comfortably fits a device URL.
+package qrcode
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+)
+
+// ErrTooLong is returned when the text does not fit in the largest supported
+// version.
+var ErrTooLong = errors.New("qrcode: text too long")
+
+// Code is an encoded QR symbol. Modules are indexed by column x and row y.
+type Code struct {
+	Version int
+	Size    int
+	modules [][]bool
+}
+
+// Dark reports whether the module at column x, row y is dark.
+func (c *Code) Dark(x, y int) bool {
+	if x < 0 || y < 0 || x >= c.Size || y >= c.Size {
+		return false
+	}
+	return c.modules[y][x]
+}
+
+// versionInfo holds the level M block structure for one version.
+type versionInfo struct {
+	ecPerBlock int
+	groups     [][2]int // {block count, data codewords per block}
+	align      []int
+}
+
+var versions = []versionInfo{
+	1:  {10, [][2]int{{1, 16}}, nil},
+	2:  {16, [][2]int{{1, 28}}, []int{6, 18}},
+	3:  {26, [][2]int{{1, 44}}, []int{6, 22}},
+	4:  {18, [][2]int{{2, 32}}, []int{6, 26}},
+	5:  {24, [][2]int{{2, 43}}, []int{6, 30}},
+	6:  {16, [][2]int{{4, 27}}, []int{6, 34}},
+	7:  {18, [][2]int{{4, 31}}, []int{6, 22, 38}},
+	8:  {22, [][2]int{{2, 38}, {2, 39}}, []int{6, 24, 42}},
+	9:  {22, [][2]int{{3, 36}, {2, 37}}, []int{6, 26, 46}},
+	10: {26, [][2]int{{4, 43}, {1, 44}}, []int{6, 28, 50}},
+}
+
+func (v versionInfo) dataCodewords() int {
+	n := 0
+	for _, g := range v.groups {
+		n += g[0] * g[1]
+	}
+	return n
+}
+
+// Encode encodes text in byte mode using the smallest version that fits.
+func Encode(text string) (*Code, error) {
+	data := []byte(text)
+	for version := 1; version < len(versions); version++ {
+		countBits := 8
+		if version >= 10 {
+			countBits = 16
+		}
+		capacityBits := versions[version].dataCodewords() * 8
+		if 4+countBits+len(data)*8 > capacityBits {
+			continue
+		}
+		codewords := encodeData(data, countBits, capacityBits/8)
+		return build(version, addErrorCorrection(version, codewords)), nil
+	}
+	return nil, ErrTooLong
+}
+
+// encodeData builds the data codewords: mode, length, payload, terminator
+// and padding.
+func encodeData(data []byte, countBits, capacity int) []byte {
+	var bb bitBuffer
+	bb.append(0x4, 4) // byte mode
+	bb.append(uint32(len(data)), countBits)
+	for _, b := range data {
+		bb.append(uint32(b), 8)
+	}
+	bb.append(0, min(4, capacity*8-bb.len()))
+	if r := bb.len() % 8; r != 0 {
+		bb.append(0, 8-r)
+	}
+	out := bb.bytes()
+	for pad := byte(0xEC); len(out) < capacity; pad ^= 0xEC ^ 0x11 {
+		out = append(out, pad)
+	}
+	return out
+}
+
+// addErrorCorrection splits data into blocks, appends Reed-Solomon codewords
+// and interleaves the result.
+func addErrorCorrection(version int, data []byte) []byte {
+	info := versions[version]
+	divisor := rsDivisor(info.ecPerBlock)
+
+	var blocks, ecBlocks [][]byte
+	offset := 0
+	for _, g := range info.groups {
+		for i := 0; i < g[0]; i++ {
+			block := data[offset : offset+g[1]]
+			offset += g[1]
+			blocks = append(blocks, block)
+			ecBlocks = append(ecBlocks, rsRemainder(block, divisor))
+		}
+	}
+
+	var out []byte
+	maxData := info.groups[len(info.groups)-1][1]
+	for i := 0; i < maxData; i++ {
+		for _, b := range blocks {
+			if i < len(b) {
+				out = append(out, b[i])
+			}
+		}
+	}
+	for i := 0; i < info.ecPerBlock; i++ {
+		for _, b := range ecBlocks {
+			out = append(out, b[i])
+		}
+	}
+	return out
+}
+
+// build lays out function patterns and codewords, then applies the mask
+// with the lowest penalty.
+func build(version int, codewords []byte) *Code {
+	size := 17 + 4*version
+	base := newGrid(size)
+	drawFunctionPatterns(base, version)
+	drawCodewords(base, codewords)
+
+	var best *grid
+	bestPenalty := -1
+	for mask := 0; mask < 8; mask++ {
+		g := base.clone()
+		applyMask(g, mask)
+		drawFormatBits(g, mask)
+		if p := penalty(g); bestPenalty < 0 || p < bestPenalty {
+			best, bestPenalty = g, p
+		}
+	}
+	return &Code{Version: version, Size: size, modules: best.modules}
+}
+
+// SVG renders the code as a standalone SVG image with the standard four
+// module quiet zone. scale is the size of one module in pixels.
+func (c *Code) SVG(scale int) []byte {
+	if scale < 1 {
+		scale = 1
+	}
+	const border = 4
+	dim := (c.Size + 2*border) * scale
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d" shape-rendering="crispEdges">`,
+		dim, dim, c.Size+2*border, c.Size+2*border)
+	fmt.Fprintf(&buf, `<rect width="100%%" height="100%%" fill="#fff"/><path fill="#000" d="`)
+	for y := 0; y < c.Size; y++ {
+		for x := 0; x < c.Size; x++ {
+			if c.modules[y][x] {
+				fmt.Fprintf(&buf, "M%d,%dh1v1h-1z", x+border, y+border)
+			}
+		}
+	}
+	buf.WriteString(`"/></svg>`)
+	return buf.Bytes()
+}
+
+// ---------- Layout ----------
+
+type grid struct {
+	size       int
+	modules    [][]bool
+	isFunction [][]bool
+}
+
+func newGrid(size int) *grid {
+	g := &grid{size: size, modules: make([][]bool, size), isFunction: make([][]bool, size)}
+	for i := range g.modules {
+		g.modules[i] = make([]bool, size)
+		g.isFunction[i] = make([]bool, size)
+	}
+	return g
+}
+
+func (g *grid) clone() *grid {
+	c := newGrid(g.size)
+	for y := range g.modules {
+		copy(c.modules[y], g.modules[y])
+		copy(c.isFunction[y], g.isFunction[y])
+	}
+	return c
+}
+
+func (g *grid) setFunction(x, y int, dark bool) {
+	g.modules[y][x] = dark
+	g.isFunction[y][x] = true
+}
+
+func drawFunctionPatterns(g *grid, version int) {
+	for i := 0; i < g.size; i++ {
+		g.setFunction(6, i, i%2 == 0)
+		g.setFunction(i, 6, i%2 == 0)
+	}
+
+	for _, c := range [][2]int{{3, 3}, {g.size - 4, 3}, {3, g.size - 4}} {
+		for dy := -4; dy <= 4; dy++ {
+			for dx := -4; dx <= 4; dx++ {
+				x, y := c[0]+dx, c[1]+dy
+				if x < 0 || y < 0 || x >= g.size || y >= g.size {
+					continue
+				}
+				dist := max(abs(dx), abs(dy))
+				g.setFunction(x, y, dist != 2 && dist != 4)
+			}
+		}
+	}
+
+	align := versions[version].align
+	last := len(align) - 1
+	for i, cx := range align {
+		for j, cy := range align {
+			// Skip the three corners occupied by finder patterns
+			if (i == 0 && j == 0) || (i == 0 && j == last) || (i == last && j == 0) {
+				continue
+			}
+			for dy := -2; dy <= 2; dy++ {
+				for dx := -2; dx <= 2; dx++ {
+					g.setFunction(cx+dx, cy+dy, max(abs(dx), abs(dy)) != 1)
+				}
+			}
+		}
+	}
+
+	// Reserve the format areas; real bits are drawn once the mask is chosen
+	drawFormatBits(g, 0)
+
+	if version >= 7 {
+		bits := versionBits(version)
+		for i := 0; i < 18; i++ {
+			dark := (bits>>i)&1 != 0
+			a, b := g.size-11+i%3, i/3
+			g.setFunction(a, b, dark)
+			g.setFunction(b, a, dark)
+		}
+	}
+}
+
+// formatBits returns the 15-bit format information for level M and mask.
+func formatBits(mask int) uint32 {
+	data := uint32(0)<<3 | uint32(mask) // level M is 00
+	rem := data
+	for i := 0; i < 10; i++ {
+		rem = (rem << 1) ^ ((rem >> 9) * 0x537)
+	}
+	return (data<<10 | rem) ^ 0x5412
+}
+
+// versionBits returns the 18-bit version information (versions 7+).
+func versionBits(version int) uint32 {
+	rem := uint32(version)
+	for i := 0; i < 12; i++ {
+		rem = (rem << 1) ^ ((rem >> 11) * 0x1F25)
+	}
+	return uint32(version)<<12 | rem
+}
+
+func drawFormatBits(g *grid, mask int) {
+	bits := formatBits(mask)
+	bit := func(i int) bool { return (bits>>i)&1 != 0 }
+
+	for i := 0; i <= 5; i++ {
+		g.setFunction(8, i, bit(i))
+	}
+	g.setFunction(8, 7, bit(6))
+	g.setFunction(8, 8, bit(7))
+	g.setFunction(7, 8, bit(8))
+	for i := 9; i < 15; i++ {
+		g.setFunction(14-i, 8, bit(i))
+	}
+
+	for i := 0; i < 8; i++ {
+		g.setFunction(g.size-1-i, 8, bit(i))
+	}
+	for i := 8; i < 15; i++ {
+		g.setFunction(8, g.size-15+i, bit(i))
+	}
+	g.setFunction(8, g.size-8, true) // dark module
+}
+
+// drawCodewords places data in the two-column zigzag from the bottom right,
+// skipping the vertical timing pattern.
+func drawCodewords(g *grid, data []byte) {
+	i := 0
+	for right := g.size - 1; right >= 1; right -= 2 {
+		if right == 6 {
+			right = 5
+		}
+		upward := (right+1)&2 == 0
+		for vert := 0; vert < g.size; vert++ {
+			y := vert
+			if upward {
+				y = g.size - 1 - vert
+			}
+			for j := 0; j < 2; j++ {
+				x := right - j
+				if g.isFunction[y][x] || i >= len(data)*8 {
+					continue
+				}
+				g.modules[y][x] = (data[i>>3]>>(7-uint(i&7)))&1 != 0
+				i++
+			}
+		}
+	}
+}
+
+func applyMask(g *grid, mask int) {
+	for y := 0; y < g.size; y++ {
+		for x := 0; x < g.size; x++ {
+			if g.isFunction[y][x] {
+				continue
+			}
+			var invert bool
+			switch mask {
+			case 0:
+				invert = (x+y)%2 == 0
+			case 1:
+				invert = y%2 == 0
+			case 2:
+				invert = x%3 == 0
+			case 3:
+				invert = (x+y)%3 == 0
+			case 4:
+				invert = (x/3+y/2)%2 == 0
+			case 5:
+				invert = x*y%2+x*y%3 == 0
+			case 6:
+				invert = (x*y%2+x*y%3)%2 == 0
+			case 7:
+				invert = ((x+y)%2+x*y%3)%2 == 0
+			}
+			if invert {
+				g.modules[y][x] = !g.modules[y][x]
+			}
+		}
+	}
+}
+
+// penalty scores a masked symbol using the four rules from the standard.
+func penalty(g *grid) int {
+	n := g.size
+	at := func(x, y int, transpose bool) bool {
+		if transpose {
+			return g.modules[x][y]
+		}
+		return g.modules[y][x]
+	}
+	score := 0
+
+	for _, transpose := range []bool{false, true} {
+		for y := 0; y < n; y++ {
+			// Rule 1: runs of five or more same-colored modules
+			run := 1
+			for x := 1; x < n; x++ {
+				if at(x, y, transpose) == at(x-1, y, transpose) {
+					run++
+					continue
+				}
+				if run >= 5 {
+					score += run - 2
+				}
+				run = 1
+			}
+			if run >= 5 {
+				score += run - 2
+			}
+			// Rule 3: finder-like 1:1:3:1:1 patterns with four light modules
+			for x := 0; x+10 < n; x++ {
+				if matchesFinderLike(func(i int) bool { return at(x+i, y, transpose) }) {
+					score += 40
+				}
+			}
+		}
+	}
+
+	// Rule 2: 2x2 blocks of one color
+	for y := 0; y+1 < n; y++ {
+		for x := 0; x+1 < n; x++ {
+			c := g.modules[y][x]
+			if c == g.modules[y][x+1] && c == g.modules[y+1][x] && c == g.modules[y+1][x+1] {
+				score += 3
+			}
+		}
+	}
+
+	// Rule 4: balance of dark and light modules
+	dark := 0
+	for y := 0; y < n; y++ {
+		for x := 0; x < n; x++ {
+			if g.modules[y][x] {
+				dark++
+			}
+		}
+	}
+	total := n * n
+	k := (abs(dark*20-total*10)+total-1)/total - 1
+	score += k * 10
+	return score
+}
+
+var finderLikeA = [11]bool{true, false, true, true, true, false, true, false, false, false, false}
+var finderLikeB = [11]bool{false, false, false, false, true, false, true, true, true, false, true}
+
+func matchesFinderLike(at func(int) bool) bool {
+	matchA, matchB := true, true
+	for i := 0; i < 11; i++ {
+		v := at(i)
+		matchA = matchA && v == finderLikeA[i]
+		matchB = matchB && v == finderLikeB[i]
+	}
+	return matchA || matchB
+}
+
+// ---------- Reed-Solomon over GF(256) ----------
+
+func gfMul(a, b byte) byte {
+	var z int
+	for i := 7; i >= 0; i-- {
+		z = (z << 1) ^ ((z >> 7) * 0x11D)
+		z ^= int((b>>uint(i))&1) * int(a)
+	}
+	return byte(z)
+}
+
+// rsDivisor returns the generator polynomial of the given degree, highest
+// coefficient first and the leading 1 omitted.
+func rsDivisor(degree int) []byte {
+	result := make([]byte, degree)
+	result[degree-1] = 1
+	root := byte(1)
+	for i := 0; i < degree; i++ {
+		for j := range result {
+			result[j] = gfMul(result[j], root)
+			if j+1 < len(result) {
+				result[j] ^= result[j+1]
+			}
+		}
+		root = gfMul(root, 0x02)
+	}
+	return result
+}
+
+func rsRemainder(data, divisor []byte) []byte {
+	result := make([]byte, len(divisor))
+	for _, b := range data {
+		factor := b ^ result[0]
+		copy(result, result[1:])
+		result[len(result)-1] = 0
+		for i := range result {
+			result[i] ^= gfMul(divisor[i], factor)
+		}
+	}
+	return result
+}
+
+// ---------- Helpers ----------
+
+type bitBuffer struct {
+	bits []bool
+}
+
+func (b *bitBuffer) append(v uint32, n int) {
+	for i := n - 1; i >= 0; i-- {
+		b.bits = append(b.bits, (v>>uint(i))&1 != 0)
+	}
+}
+
+func (b *bitBuffer) len() int { return len(b.bits) }
+
+func (b *bitBuffer) bytes() []byte {
+	out := make([]byte, (len(b.bits)+7)/8)
+	for i, bit := range b.bits {
+		if bit {
+			out[i/8] |= 1 << uint(7-i%8)
+		}
+	}
+	return out
+}
+
+func abs(v int) int {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
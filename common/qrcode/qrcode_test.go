@@ -0,0 +1,196 @@
+package qrcode
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestRSRemainder(t *testing.T) {
+	t.Parallel()
+	// "HELLO WORLD" at 1-M, from the worked example in the standard's tutorials
+	data := []byte{0x20, 0x5B, 0x0B, 0x78, 0xD1, 0x72, 0xDC, 0x4D, 0x43, 0x40, 0xEC, 0x11, 0xEC, 0x11, 0xEC, 0x11}
+	want := []byte{0xC4, 0x23, 0x27, 0x77, 0xEB, 0xD7, 0xE7, 0xE2, 0x5D, 0x17}
+	if got := rsRemainder(data, rsDivisor(10)); !bytes.Equal(got, want) {
+		t.Fatalf("rsRemainder = % X, want % X", got, want)
+	}
+}
+
+func TestFormatAndVersionBits(t *testing.T) {
+	t.Parallel()
+	if got := formatBits(5); got != 0b100000011001110 {
+		t.Errorf("formatBits(5) = %015b", got)
+	}
+	if got := versionBits(7); got != 0b000111110010010100 {
+		t.Errorf("versionBits(7) = %018b", got)
+	}
+}
+
+// decode reads a symbol back the way a scanner would: it recovers the mask
+// from the format bits, unmasks the data area and de-interleaves the blocks.
+func decode(t *testing.T, c *Code) string {
+	t.Helper()
+	var format uint32
+	for i := 0; i <= 5; i++ {
+		if c.Dark(8, i) {
+			format |= 1 << i
+		}
+	}
+	for i, pos := range [][2]int{{8, 7}, {8, 8}, {7, 8}} {
+		if c.Dark(pos[0], pos[1]) {
+			format |= 1 << (6 + i)
+		}
+	}
+	for i := 9; i < 15; i++ {
+		if c.Dark(14-i, 8) {
+			format |= 1 << i
+		}
+	}
+	mask := -1
+	for m := 0; m < 8; m++ {
+		if formatBits(m) == format {
+			mask = m
+		}
+	}
+	if mask < 0 {
+		t.Fatalf("unreadable format bits %015b", format)
+	}
+
+	layout := newGrid(c.Size)
+	drawFunctionPatterns(layout, c.Version)
+	for y := 0; y < c.Size; y++ {
+		copy(layout.modules[y], c.modules[y])
+	}
+	applyMask(layout, mask)
+
+	info := versions[c.Version]
+	total := info.dataCodewords()
+	blocks := 0
+	for _, g := range info.groups {
+		blocks += g[0]
+	}
+	total += blocks * info.ecPerBlock
+
+	var raw []byte
+	var cur byte
+	n := 0
+	for right := c.Size - 1; right >= 1; right -= 2 {
+		if right == 6 {
+			right = 5
+		}
+		for vert := 0; vert < c.Size; vert++ {
+			y := vert
+			if (right+1)&2 == 0 {
+				y = c.Size - 1 - vert
+			}
+			for j := 0; j < 2; j++ {
+				x := right - j
+				if layout.isFunction[y][x] || len(raw) == total {
+					continue
+				}
+				cur <<= 1
+				if layout.modules[y][x] {
+					cur |= 1
+				}
+				if n++; n == 8 {
+					raw, cur, n = append(raw, cur), 0, 0
+				}
+			}
+		}
+	}
+
+	// De-interleave and check each block's error correction
+	var sizes []int
+	for _, g := range info.groups {
+		for i := 0; i < g[0]; i++ {
+			sizes = append(sizes, g[1])
+		}
+	}
+	data := make([][]byte, len(sizes))
+	pos := 0
+	for i := 0; pos < info.dataCodewords(); i++ {
+		for b, size := range sizes {
+			if i < size {
+				data[b] = append(data[b], raw[pos])
+				pos++
+			}
+		}
+	}
+	divisor := rsDivisor(info.ecPerBlock)
+	for i := 0; i < info.ecPerBlock; i++ {
+		for b := range sizes {
+			want := rsRemainder(data[b], divisor)
+			if raw[pos] != want[i] {
+				t.Fatalf("block %d ec codeword %d mismatch", b, i)
+			}
+			pos++
+		}
+	}
+
+	stream := bytes.Join(data, nil)
+	if stream[0]>>4 != 0x4 {
+		t.Fatalf("expected byte mode, got %X", stream[0]>>4)
+	}
+	var bb bitBuffer
+	for _, b := range stream {
+		bb.append(uint32(b), 8)
+	}
+	read := func(from, n int) int {
+		v := 0
+		for i := 0; i < n; i++ {
+			v <<= 1
+			if bb.bits[from+i] {
+				v = v | 1
+			}
+		}
+		return v
+	}
+	countBits := 8
+	if c.Version >= 10 {
+		countBits = 16
+	}
+	length := read(4, countBits)
+	out := make([]byte, length)
+	for i := range out {
+		out[i] = byte(read(4+countBits+8*i, 8))
+	}
+	return string(out)
+}
+
+func TestEncodeRoundTrip(t *testing.T) {
+	t.Parallel()
+	cases := map[string]int{
+		"PM": 1,
+		"https://printer-agent.local:8443/asset?code=JPBCD12345": 4,
+		strings.Repeat("x", 150):                                 8,
+		strings.Repeat("y", 213):                                 10,
+	}
+	for text, version := range cases {
+		c, err := Encode(text)
+		if err != nil {
+			t.Fatalf("Encode(%d bytes): %v", len(text), err)
+		}
+		if c.Version != version || c.Size != 17+4*version {
+			t.Errorf("Encode(%d bytes) version %d size %d, want version %d", len(text), c.Version, c.Size, version)
+		}
+		if got := decode(t, c); got != text {
+			t.Errorf("round trip mismatch: got %q want %q", got, text)
+		}
+	}
+
+	if _, err := Encode(strings.Repeat("z", 214)); err != ErrTooLong {
+		t.Fatalf("expected ErrTooLong, got %v", err)
+	}
+}
+
+func TestSVG(t *testing.T) {
+	t.Parallel()
+	c, err := Encode("SN123")
+	if err != nil {
+		t.Fatalf("Encode: %v", err)
+	}
+	svg := string(c.SVG(4))
+	if !strings.HasPrefix(svg, "<svg") || !strings.Contains(svg, `width="116"`) || !strings.HasSuffix(svg, "</svg>") {
+		t.Fatalf("unexpected svg: %.120s", svg)
+	}
+}
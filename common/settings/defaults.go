@@ -34,11 +34,21 @@ func DefaultSettings() Settings {
 			AutoDiscoverLiveSSDP:     false,
 			AutoDiscoverLiveSNMPTrap: false,
 			AutoDiscoverLiveLLMNR:    false,
+			SNMPTrapFallbackPort:     10162,
 
 			// Metrics Collection
 			MetricsRescanEnabled:         false,
 			MetricsRescanIntervalMinutes: 60,
 			MetricsRescanIntervalSeconds: 0, // 0 means use minutes-based interval
+
+			// Service Scan
+			ServiceScanEnabled:       false,
+			ServiceScanMaxPerMinute:  6,
+			ServiceScanIntervalHours: 24,
+
+			// Meter Reads
+			MeterReadEnabled:    false,
+			MeterReadDayOfMonth: 1,
 		},
 		SNMP: SNMPSettings{
 			Version:       "2c",
@@ -52,11 +62,17 @@ func DefaultSettings() Settings {
 			PrivProtocol:  "",
 			PrivPassword:  "",
 			ContextName:   "",
+
+			CommunitySweepEnabled:   false,
+			CommunityCandidates:     "",
+			CommunitySweepPerSecond: 2,
 		},
 		Features: FeaturesSettings{
-			EpsonRemoteModeEnabled: false,
-			CredentialsEnabled:     true,
-			AssetIDRegex:           "",
+			EpsonRemoteModeEnabled:        false,
+			CredentialsEnabled:            true,
+			AssetIDRegex:                  "",
+			StatusPageOCREnabled:          false,
+			UnknownDeviceTelemetryEnabled: false,
 		},
 		Spooler: SpoolerSettings{
 			Enabled:                true,
@@ -77,5 +93,10 @@ func DefaultSettings() Settings {
 			CustomCertPath:      "",
 			CustomKeyPath:       "",
 		},
+		Notifications: NotificationSettings{
+			DesktopEnabled: true,
+			ScanComplete:   true,
+			DeviceErrors:   true,
+		},
 	}
 }
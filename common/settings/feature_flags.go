@@ -0,0 +1,79 @@
+package settings
+
+// Feature flags are server-controlled entitlements. Unlike Settings they are
+// not part of the fleet settings patch chain, so tenant users cannot change
+// them: a server admin sets a server-wide default and optional per-tenant
+// overrides, and agents receive the resolved values with their managed
+// settings snapshot.
+const (
+	// FeatureFirmwareUpdates lets agents download and install updates from the server.
+	FeatureFirmwareUpdates = "firmware_updates"
+	// FeatureRemoteProxy lets server users open the agent UI and device web UIs through the server.
+	FeatureRemoteProxy = "remote_proxy"
+	// FeatureJobAccounting lets agents track print jobs from the local print spooler.
+	FeatureJobAccounting = "job_accounting"
+)
+
+// FeatureFlagDefinition describes a feature flag for APIs and UIs.
+type FeatureFlagDefinition struct {
+	Key         string `json:"key"`
+	Title       string `json:"title"`
+	Description string `json:"description"`
+	Default     bool   `json:"default"`
+}
+
+// FeatureFlagDefinitions lists every known flag. Defaults preserve the
+// behavior from before the flags existed.
+var FeatureFlagDefinitions = []FeatureFlagDefinition{
+	{
+		Key:         FeatureFirmwareUpdates,
+		Title:       "Firmware Updates",
+		Description: "Agents download and install new agent releases from this server.",
+		Default:     true,
+	},
+	{
+		Key:         FeatureRemoteProxy,
+		Title:       "Remote Proxy",
+		Description: "Server users can open the agent UI and printer web pages through the server.",
+		Default:     true,
+	},
+	{
+		Key:         FeatureJobAccounting,
+		Title:       "Job Accounting",
+		Description: "Agents record print jobs from the local print spooler.",
+		Default:     true,
+	},
+}
+
+// IsKnownFeatureFlag reports whether key names a defined feature flag.
+func IsKnownFeatureFlag(key string) bool {
+	for _, def := range FeatureFlagDefinitions {
+		if def.Key == key {
+			return true
+		}
+	}
+	return false
+}
+
+// DefaultFeatureFlags returns every flag set to its default.
+func DefaultFeatureFlags() map[string]bool {
+	flags := make(map[string]bool, len(FeatureFlagDefinitions))
+	for _, def := range FeatureFlagDefinitions {
+		flags[def.Key] = def.Default
+	}
+	return flags
+}
+
+// FeatureEnabled reports whether key is enabled in flags, falling back to the
+// flag's default when flags does not mention it (e.g. an older server).
+func FeatureEnabled(flags map[string]bool, key string) bool {
+	if enabled, ok := flags[key]; ok {
+		return enabled
+	}
+	for _, def := range FeatureFlagDefinitions {
+		if def.Key == key {
+			return def.Default
+		}
+	}
+	return false
+}
@@ -4,9 +4,14 @@ import (
 	"crypto/sha256"
 	"encoding/hex"
 	"encoding/json"
+	"net/netip"
+	"strings"
 	"time"
 )
 
+// MaxCommunityCandidates bounds the SNMP community sweep list.
+const MaxCommunityCandidates = 20
+
 var agentLocalDefaults = DefaultSettings()
 
 // StripAgentLocalFields resets agent-local fields to their defaults so server-managed
@@ -19,6 +24,8 @@ func StripAgentLocalFields(cfg *Settings) {
 	cfg.Logging = agentLocalDefaults.Logging
 	// Web section is agent-local
 	cfg.Web = agentLocalDefaults.Web
+	// Notifications section is agent-local
+	cfg.Notifications = agentLocalDefaults.Notifications
 }
 
 // CopyAgentLocalFields copies agent-local fields from src into dst.
@@ -30,6 +37,8 @@ func CopyAgentLocalFields(src Settings, dst *Settings) {
 	dst.Logging = src.Logging
 	// Web section is agent-local
 	dst.Web = src.Web
+	// Notifications section is agent-local
+	dst.Notifications = src.Notifications
 }
 
 // ComputeSettingsVersion hashes the schema version, update timestamp, and settings payload
@@ -51,3 +60,44 @@ func ComputeSettingsVersion(schemaVersion string, updatedAt time.Time, cfg Setti
 	h := sha256.Sum256(b)
 	return hex.EncodeToString(h[:]), nil
 }
+
+// CommunityCandidateList splits the newline-separated community sweep list,
+// dropping blank lines and duplicates while keeping the configured order.
+func CommunityCandidateList(text string) []string {
+	var out []string
+	seen := make(map[string]bool)
+	for _, line := range strings.Split(text, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || seen[line] {
+			continue
+		}
+		seen[line] = true
+		out = append(out, line)
+	}
+	return out
+}
+
+// ScanScopePrefixes parses the authorized scan scope: CIDRs or single IPs
+// separated by newlines or commas, with '#' starting a comment. Entries that
+// are neither are returned in invalid.
+func ScanScopePrefixes(text string) (prefixes []netip.Prefix, invalid []string) {
+	for _, line := range strings.Split(text, "\n") {
+		if i := strings.IndexByte(line, '#'); i >= 0 {
+			line = line[:i]
+		}
+		for _, entry := range strings.Split(line, ",") {
+			entry = strings.TrimSpace(entry)
+			if entry == "" {
+				continue
+			}
+			if p, err := netip.ParsePrefix(entry); err == nil {
+				prefixes = append(prefixes, p.Masked())
+			} else if addr, err := netip.ParseAddr(entry); err == nil {
+				prefixes = append(prefixes, netip.PrefixFrom(addr.Unmap(), addr.Unmap().BitLen()))
+			} else {
+				invalid = append(invalid, entry)
+			}
+		}
+	}
+	return prefixes, invalid
+}
@@ -0,0 +1,257 @@
+package settings
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// JSONSchemaDialect is the JSON Schema draft the settings schema follows.
+const JSONSchemaDialect = "https://json-schema.org/draft/2020-12/schema"
+
+// Validation error codes reported in ValidationError.Code.
+const (
+	CodeUnknownField = "unknown_field"
+	CodeType         = "type"
+	CodeMinimum      = "minimum"
+	CodeMaximum      = "maximum"
+	CodeEnum         = "enum"
+)
+
+// JSONSchema is the subset of JSON Schema used to describe settings. The
+// x- keywords carry the FieldMeta details UIs need to render forms.
+type JSONSchema struct {
+	Dialect              string                 `json:"$schema,omitempty"`
+	Version              string                 `json:"version,omitempty"`
+	Title                string                 `json:"title,omitempty"`
+	Description          string                 `json:"description,omitempty"`
+	Type                 string                 `json:"type"`
+	Properties           map[string]*JSONSchema `json:"properties,omitempty"`
+	Order                []string               `json:"x-order,omitempty"`
+	AdditionalProperties *bool                  `json:"additionalProperties,omitempty"`
+	Default              interface{}            `json:"default,omitempty"`
+	Minimum              *float64               `json:"minimum,omitempty"`
+	Maximum              *float64               `json:"maximum,omitempty"`
+	Enum                 []string               `json:"enum,omitempty"`
+	ReadOnly             bool                   `json:"readOnly,omitempty"`
+	WriteOnly            bool                   `json:"writeOnly,omitempty"`
+	Widget               FieldType              `json:"x-widget,omitempty"`
+	Scope                Scope                  `json:"x-scope,omitempty"`
+	EditableBy           []EditableRole         `json:"x-editable-by,omitempty"`
+}
+
+// sectionTitles names the top-level sections of Settings.
+var sectionTitles = map[string]string{
+	"discovery":     "Discovery",
+	"snmp":          "SNMP",
+	"features":      "Features",
+	"spooler":       "Local Printers",
+	"logging":       "Logging",
+	"web":           "Web Server",
+	"notifications": "Desktop Notifications",
+}
+
+// Fields derived by the agent rather than set by users.
+var readOnlyFields = map[string]bool{
+	"discovery.detected_subnet": true,
+}
+
+// Secrets that are accepted but should not be echoed into forms.
+var writeOnlyFields = map[string]bool{
+	"snmp.auth_password": true,
+	"snmp.priv_password": true,
+}
+
+// SettingsJSONSchema describes Settings as a JSON Schema. Every field of the
+// Go types is listed, so new settings appear without further changes; fields
+// with FieldMeta entries also carry their title, description, default and
+// bounds.
+func SettingsJSONSchema() *JSONSchema {
+	meta := make(map[string]FieldMeta)
+	for _, f := range DefaultSchema().Fields {
+		meta[f.Path] = f
+	}
+
+	root := &JSONSchema{
+		Dialect:              JSONSchemaDialect,
+		Version:              SchemaVersion,
+		Title:                "PrintMaster agent settings",
+		Type:                 "object",
+		Properties:           map[string]*JSONSchema{},
+		AdditionalProperties: boolPtr(false),
+	}
+	st := reflect.TypeOf(Settings{})
+	for i := 0; i < st.NumField(); i++ {
+		section := jsonName(st.Field(i))
+		if section == "" {
+			continue
+		}
+		sec := &JSONSchema{
+			Title:                sectionTitles[section],
+			Type:                 "object",
+			Properties:           map[string]*JSONSchema{},
+			AdditionalProperties: boolPtr(false),
+		}
+		ft := st.Field(i).Type
+		for j := 0; j < ft.NumField(); j++ {
+			name := jsonName(ft.Field(j))
+			if name == "" {
+				continue
+			}
+			path := section + "." + name
+			prop := &JSONSchema{Type: jsonType(ft.Field(j).Type.Kind()), ReadOnly: readOnlyFields[path], WriteOnly: writeOnlyFields[path]}
+			if m, ok := meta[path]; ok {
+				prop.Title, prop.Description, prop.Default = m.Title, m.Description, m.Default
+				prop.Minimum, prop.Maximum, prop.Enum = m.Min, m.Max, m.Enum
+				prop.Widget, prop.Scope, prop.EditableBy = m.Type, m.Scope, m.EditableBy
+			}
+			sec.Properties[name] = prop
+			sec.Order = append(sec.Order, name)
+		}
+		root.Properties[section] = sec
+		root.Order = append(root.Order, section)
+	}
+	return root
+}
+
+// ValidateSection checks one section of a settings update, as decoded from
+// JSON, against the schema. Errors name the offending field both as a dotted
+// field path and as a JSON Pointer.
+func ValidateSection(schema *JSONSchema, section string, values map[string]interface{}) []ValidationError {
+	sec := schema.Properties[section]
+	if sec == nil {
+		return []ValidationError{newValidationError(section, CodeUnknownField, "unknown settings section")}
+	}
+	return sec.validate(section, values)
+}
+
+func (s *JSONSchema) validate(path string, value interface{}) []ValidationError {
+	switch s.Type {
+	case "object":
+		obj, ok := value.(map[string]interface{})
+		if !ok {
+			return []ValidationError{typeError(path, "object", value)}
+		}
+		keys := make([]string, 0, len(obj))
+		for k := range obj {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		var errs []ValidationError
+		for _, k := range keys {
+			prop := s.Properties[k]
+			if prop == nil {
+				if s.AdditionalProperties == nil || *s.AdditionalProperties {
+					continue
+				}
+				errs = append(errs, newValidationError(path+"."+k, CodeUnknownField, "unknown setting"))
+				continue
+			}
+			errs = append(errs, prop.validate(path+"."+k, obj[k])...)
+		}
+		return errs
+	case "boolean":
+		if _, ok := value.(bool); !ok {
+			return []ValidationError{typeError(path, "boolean", value)}
+		}
+	case "string":
+		str, ok := value.(string)
+		if !ok {
+			return []ValidationError{typeError(path, "string", value)}
+		}
+		if len(s.Enum) > 0 && !containsString(s.Enum, str) {
+			return []ValidationError{newValidationError(path, CodeEnum, "must be one of: "+strings.Join(s.Enum, ", "))}
+		}
+	case "integer", "number":
+		n, ok := value.(float64)
+		if !ok || (s.Type == "integer" && n != math.Trunc(n)) {
+			return []ValidationError{typeError(path, s.Type, value)}
+		}
+		if s.Minimum != nil && n < *s.Minimum {
+			return []ValidationError{newValidationError(path, CodeMinimum, fmt.Sprintf("must be at least %v", *s.Minimum))}
+		}
+		if s.Maximum != nil && n > *s.Maximum {
+			return []ValidationError{newValidationError(path, CodeMaximum, fmt.Sprintf("must be at most %v", *s.Maximum))}
+		}
+	}
+	return nil
+}
+
+func newValidationError(field, code, message string) ValidationError {
+	return ValidationError{
+		Field:   field,
+		Path:    "/" + strings.ReplaceAll(field, ".", "/"),
+		Code:    code,
+		Message: message,
+	}
+}
+
+func typeError(path, want string, got interface{}) ValidationError {
+	return newValidationError(path, CodeType, fmt.Sprintf("must be %s, got %s", article(want), jsonTypeOf(got)))
+}
+
+func article(t string) string {
+	if t == "integer" || t == "object" {
+		return "an " + t
+	}
+	return "a " + t
+}
+
+func jsonTypeOf(v interface{}) string {
+	switch v.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case string:
+		return "string"
+	case float64:
+		return "number"
+	case map[string]interface{}:
+		return "object"
+	case []interface{}:
+		return "array"
+	}
+	return fmt.Sprintf("%T", v)
+}
+
+func jsonName(f reflect.StructField) string {
+	tag := f.Tag.Get("json")
+	if tag == "-" || !f.IsExported() {
+		return ""
+	}
+	if name, _, _ := strings.Cut(tag, ","); name != "" {
+		return name
+	}
+	return f.Name
+}
+
+func jsonType(k reflect.Kind) string {
+	switch k {
+	case reflect.Bool:
+		return "boolean"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return "integer"
+	case reflect.Float32, reflect.Float64:
+		return "number"
+	case reflect.Struct, reflect.Map:
+		return "object"
+	}
+	return "string"
+}
+
+func containsString(list []string, v string) bool {
+	for _, s := range list {
+		if s == v {
+			return true
+		}
+	}
+	return false
+}
+
+func boolPtr(b bool) *bool {
+	return &b
+}
@@ -0,0 +1,65 @@
+package settings
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestSettingsJSONSchemaCoversSettings(t *testing.T) {
+	schema := SettingsJSONSchema()
+	if schema.Properties["snmp"] == nil || schema.Properties["snmp"].Properties["timeout_ms"] == nil {
+		t.Fatal("expected snmp.timeout_ms in schema")
+	}
+	timeout := schema.Properties["snmp"].Properties["timeout_ms"]
+	if timeout.Type != "integer" || timeout.Minimum == nil || *timeout.Minimum != 500 || timeout.Title == "" {
+		t.Fatalf("unexpected timeout_ms schema %+v", timeout)
+	}
+	if level := schema.Properties["logging"].Properties["level"]; len(level.Enum) == 0 {
+		t.Fatal("expected logging.level enum")
+	}
+	if !schema.Properties["snmp"].Properties["auth_password"].WriteOnly {
+		t.Fatal("expected auth_password to be writeOnly")
+	}
+
+	// The defaults must satisfy their own schema.
+	raw, _ := json.Marshal(DefaultSettings())
+	var doc map[string]map[string]interface{}
+	if err := json.Unmarshal(raw, &doc); err != nil {
+		t.Fatal(err)
+	}
+	for section, values := range doc {
+		if errs := ValidateSection(schema, section, values); len(errs) > 0 {
+			t.Fatalf("defaults failed validation: %+v", errs)
+		}
+	}
+}
+
+func TestValidateSection(t *testing.T) {
+	schema := SettingsJSONSchema()
+	var values map[string]interface{}
+	if err := json.Unmarshal([]byte(`{"timeout_ms": 50, "retries": 1.5, "version": 2, "bogus": true}`), &values); err != nil {
+		t.Fatal(err)
+	}
+	errs := ValidateSection(schema, "snmp", values)
+	want := map[string]string{
+		"/snmp/bogus":      CodeUnknownField,
+		"/snmp/retries":    CodeType,
+		"/snmp/timeout_ms": CodeMinimum,
+		"/snmp/version":    CodeType,
+	}
+	if len(errs) != len(want) {
+		t.Fatalf("expected %d errors, got %+v", len(want), errs)
+	}
+	for _, e := range errs {
+		if want[e.Path] != e.Code {
+			t.Errorf("unexpected error %+v", e)
+		}
+	}
+
+	if errs := ValidateSection(schema, "logging", map[string]interface{}{"level": "verbose"}); len(errs) != 1 || errs[0].Code != CodeEnum || errs[0].Field != "logging.level" {
+		t.Fatalf("expected enum error, got %+v", errs)
+	}
+	if errs := ValidateSection(schema, "printers", map[string]interface{}{}); len(errs) != 1 || errs[0].Code != CodeUnknownField {
+		t.Fatalf("expected unknown section error, got %+v", errs)
+	}
+}
@@ -80,6 +80,8 @@ func DefaultSchema() Schema {
 			Scope:       ScopeTenant,
 			EditableBy:  []EditableRole{RoleServerAdmin, RoleTenantAdmin},
 			Default:     defaults.Discovery.Concurrency,
+			Min:         bound(1),
+			Max:         bound(200),
 		},
 		// ========== Discovery: Probe Methods ==========
 		{
@@ -164,6 +166,35 @@ func DefaultSchema() Schema {
 			EditableBy:  []EditableRole{RoleAgentLocal},
 			Default:     defaults.Discovery.ShowDiscoveredDevicesAnyway,
 		},
+		// ========== Discovery: Persistence Filters ==========
+		{
+			Path:        "discovery.persist_allow_patterns",
+			Type:        FieldTypeTextarea,
+			Title:       "Persist Only Matching Devices",
+			Description: "Manufacturer or model patterns (one per line, * wildcards). When set, only matching devices are saved; others are ignored.",
+			Scope:       ScopeTenant,
+			EditableBy:  []EditableRole{RoleServerAdmin, RoleTenantAdmin},
+			Default:     defaults.Discovery.PersistAllowPatterns,
+		},
+		{
+			Path:        "discovery.persist_ignore_sys_object_ids",
+			Type:        FieldTypeTextarea,
+			Title:       "Ignored sysObjectIDs",
+			Description: "SNMP sysObjectID prefixes (one per line) for devices that should never be saved, e.g. 1.3.6.1.4.1.318 for APC UPSes.",
+			Scope:       ScopeTenant,
+			EditableBy:  []EditableRole{RoleServerAdmin, RoleTenantAdmin},
+			Default:     defaults.Discovery.PersistIgnoreSysObjectIDs,
+		},
+		// ========== Discovery: Authorized Scan Scope ==========
+		{
+			Path:        "discovery.authorized_scan_scope",
+			Type:        FieldTypeTextarea,
+			Title:       "Authorized Scan Scope",
+			Description: "CIDRs or IPs (one per line) this agent may scan. When set, manual ranges, subnet scans, live-discovery follow-ups and device refreshes outside the scope are refused and logged.",
+			Scope:       ScopeTenant,
+			EditableBy:  []EditableRole{RoleServerAdmin},
+			Default:     defaults.Discovery.AuthorizedScanScope,
+		},
 		// ========== Discovery: Passive Listeners ==========
 		{
 			Path:        "discovery.passive_discovery_enabled",
@@ -210,6 +241,17 @@ func DefaultSchema() Schema {
 			EditableBy:  []EditableRole{RoleServerAdmin, RoleTenantAdmin},
 			Default:     defaults.Discovery.AutoDiscoverLiveSNMPTrap,
 		},
+		{
+			Path:        "discovery.snmp_trap_fallback_port",
+			Type:        FieldTypeNumber,
+			Title:       "SNMP Trap Fallback Port",
+			Description: "UDP port (1024-65535) the trap listener uses when the agent cannot bind port 162. Redirect 162 to it on the host or point printers at it.",
+			Scope:       ScopeTenant,
+			EditableBy:  []EditableRole{RoleServerAdmin, RoleTenantAdmin},
+			Default:     defaults.Discovery.SNMPTrapFallbackPort,
+			Min:         bound(1024),
+			Max:         bound(65535),
+		},
 		{
 			Path:        "discovery.auto_discover_live_llmnr",
 			Type:        FieldTypeBool,
@@ -237,6 +279,8 @@ func DefaultSchema() Schema {
 			Scope:       ScopeTenant,
 			EditableBy:  []EditableRole{RoleServerAdmin, RoleTenantAdmin},
 			Default:     defaults.Discovery.MetricsRescanIntervalMinutes,
+			Min:         bound(1),
+			Max:         bound(1440),
 		},
 		{
 			Path:        "discovery.metrics_rescan_interval_seconds",
@@ -246,6 +290,61 @@ func DefaultSchema() Schema {
 			Scope:       ScopeTenant,
 			EditableBy:  []EditableRole{RoleServerAdmin, RoleTenantAdmin},
 			Default:     defaults.Discovery.MetricsRescanIntervalSeconds,
+			Min:         bound(0),
+			Max:         bound(300),
+		},
+		// ========== Discovery: Service Scan ==========
+		{
+			Path:        "discovery.service_scan_enabled",
+			Type:        FieldTypeBool,
+			Title:       "Service Scan",
+			Description: "Probe discovered devices for FTP, Telnet, web, LPD, IPP and raw printing ports and accepted SNMP versions. Feeds the security posture report.",
+			Scope:       ScopeTenant,
+			EditableBy:  []EditableRole{RoleServerAdmin, RoleTenantAdmin},
+			Default:     defaults.Discovery.ServiceScanEnabled,
+		},
+		{
+			Path:        "discovery.service_scan_max_per_minute",
+			Type:        FieldTypeNumber,
+			Title:       "Service Scan Rate (devices/minute)",
+			Description: "Maximum number of devices service-scanned per minute (1-60).",
+			Scope:       ScopeTenant,
+			EditableBy:  []EditableRole{RoleServerAdmin, RoleTenantAdmin},
+			Default:     defaults.Discovery.ServiceScanMaxPerMinute,
+			Min:         bound(1),
+			Max:         bound(60),
+		},
+		{
+			Path:        "discovery.service_scan_interval_hours",
+			Type:        FieldTypeNumber,
+			Title:       "Service Scan Interval (hours)",
+			Description: "Minimum time between service scans of the same device (1-720 hours).",
+			Scope:       ScopeTenant,
+			EditableBy:  []EditableRole{RoleServerAdmin, RoleTenantAdmin},
+			Default:     defaults.Discovery.ServiceScanIntervalHours,
+			Min:         bound(1),
+			Max:         bound(720),
+		},
+		// ========== Discovery: Meter Reads ==========
+		{
+			Path:        "discovery.meter_read_enabled",
+			Type:        FieldTypeBool,
+			Title:       "Monthly Meter Reads",
+			Description: "Capture a signed, billing-grade meter read of every saved device once per month and upload it for billing reconciliation.",
+			Scope:       ScopeTenant,
+			EditableBy:  []EditableRole{RoleServerAdmin, RoleTenantAdmin},
+			Default:     defaults.Discovery.MeterReadEnabled,
+		},
+		{
+			Path:        "discovery.meter_read_day_of_month",
+			Type:        FieldTypeNumber,
+			Title:       "Meter Read Day",
+			Description: "Day of the month (1-28) on which the monthly meter read becomes due. Devices unreachable that day are read as soon as they respond.",
+			Scope:       ScopeTenant,
+			EditableBy:  []EditableRole{RoleServerAdmin, RoleTenantAdmin},
+			Default:     defaults.Discovery.MeterReadDayOfMonth,
+			Min:         bound(1),
+			Max:         bound(28),
 		},
 		// ========== SNMP (fleet-managed) ==========
 		{
@@ -265,6 +364,8 @@ func DefaultSchema() Schema {
 			Scope:       ScopeTenant,
 			EditableBy:  []EditableRole{RoleServerAdmin, RoleTenantAdmin},
 			Default:     defaults.SNMP.TimeoutMS,
+			Min:         bound(500),
+			Max:         bound(60000),
 		},
 		{
 			Path:        "snmp.retries",
@@ -274,6 +375,37 @@ func DefaultSchema() Schema {
 			Scope:       ScopeTenant,
 			EditableBy:  []EditableRole{RoleServerAdmin, RoleTenantAdmin},
 			Default:     defaults.SNMP.Retries,
+			Min:         bound(0),
+			Max:         bound(5),
+		},
+		{
+			Path:        "snmp.community_sweep_enabled",
+			Type:        FieldTypeBool,
+			Title:       "Community Sweep",
+			Description: "Authorized onboarding only: when a device ignores the configured community, try each candidate community in turn and remember the one that works. Every attempt is rate limited and audited.",
+			Scope:       ScopeTenant,
+			EditableBy:  []EditableRole{RoleServerAdmin},
+			Default:     defaults.SNMP.CommunitySweepEnabled,
+		},
+		{
+			Path:        "snmp.community_candidates",
+			Type:        FieldTypeTextarea,
+			Title:       "Candidate Communities",
+			Description: "Community strings to try during a sweep, one per line, in order (up to 20).",
+			Scope:       ScopeTenant,
+			EditableBy:  []EditableRole{RoleServerAdmin},
+			Default:     defaults.SNMP.CommunityCandidates,
+		},
+		{
+			Path:        "snmp.community_sweep_per_second",
+			Type:        FieldTypeNumber,
+			Title:       "Sweep Attempts per Second",
+			Description: "Maximum community attempts per second across all devices (1-10).",
+			Scope:       ScopeTenant,
+			EditableBy:  []EditableRole{RoleServerAdmin},
+			Default:     defaults.SNMP.CommunitySweepPerSecond,
+			Min:         bound(1),
+			Max:         bound(10),
 		},
 		// ========== Features (fleet-managed) ==========
 		{
@@ -303,6 +435,24 @@ func DefaultSchema() Schema {
 			EditableBy:  []EditableRole{RoleServerAdmin, RoleTenantAdmin},
 			Default:     defaults.Features.AssetIDRegex,
 		},
+		{
+			Path:        "features.status_page_ocr_enabled",
+			Type:        FieldTypeBool,
+			Title:       "Status Page OCR Fallback",
+			Description: "When SNMP reports no page counters, fetch the vendor status page image and OCR it (requires tesseract on the agent host). Results are flagged as low confidence.",
+			Scope:       ScopeTenant,
+			EditableBy:  []EditableRole{RoleServerAdmin, RoleTenantAdmin},
+			Default:     defaults.Features.StatusPageOCREnabled,
+		},
+		{
+			Path:        "features.unknown_device_telemetry_enabled",
+			Type:        FieldTypeBool,
+			Title:       "Unknown Device Telemetry",
+			Description: "Upload anonymized sysObjectID and sysDescr samples of printers with no recognized manufacturer to the server to improve vendor detection. IPs, MACs and serials are never sent.",
+			Scope:       ScopeTenant,
+			EditableBy:  []EditableRole{RoleServerAdmin, RoleTenantAdmin},
+			Default:     defaults.Features.UnknownDeviceTelemetryEnabled,
+		},
 		// ========== Spooler / Local Printer Tracking (fleet-managed) ==========
 		{
 			Path:        "spooler.enabled",
@@ -321,6 +471,8 @@ func DefaultSchema() Schema {
 			Scope:       ScopeTenant,
 			EditableBy:  []EditableRole{RoleServerAdmin, RoleTenantAdmin},
 			Default:     defaults.Spooler.PollIntervalSeconds,
+			Min:         bound(5),
+			Max:         bound(300),
 		},
 		{
 			Path:        "spooler.include_network_printers",
@@ -349,7 +501,7 @@ func DefaultSchema() Schema {
 			Scope:       ScopeAgentLocal,
 			EditableBy:  []EditableRole{RoleAgentLocal},
 			Default:     defaults.Logging.Level,
-			Enum:        []string{"debug", "info", "warn", "error"},
+			Enum:        []string{"trace", "debug", "info", "warn", "error"},
 		},
 		{
 			Path:        "logging.dump_parse_debug",
@@ -424,7 +576,40 @@ func DefaultSchema() Schema {
 			EditableBy:  []EditableRole{RoleAgentLocal},
 			Default:     defaults.Web.CustomKeyPath,
 		},
+		// ========== Notifications (agent-local) ==========
+		{
+			Path:        "notifications.desktop_enabled",
+			Type:        FieldTypeBool,
+			Title:       "Desktop Notifications",
+			Description: "Show native OS notifications when the agent runs interactively (not as a service).",
+			Scope:       ScopeAgentLocal,
+			EditableBy:  []EditableRole{RoleAgentLocal},
+			Default:     defaults.Notifications.DesktopEnabled,
+		},
+		{
+			Path:        "notifications.scan_complete",
+			Type:        FieldTypeBool,
+			Title:       "Notify on Scan Completion",
+			Description: "Notify when a manually started discovery scan finishes.",
+			Scope:       ScopeAgentLocal,
+			EditableBy:  []EditableRole{RoleAgentLocal},
+			Default:     defaults.Notifications.ScanComplete,
+		},
+		{
+			Path:        "notifications.device_errors",
+			Type:        FieldTypeBool,
+			Title:       "Notify on Device Errors",
+			Description: "Notify when a device reports a critical error such as a paper jam or open door.",
+			Scope:       ScopeAgentLocal,
+			EditableBy:  []EditableRole{RoleAgentLocal},
+			Default:     defaults.Notifications.DeviceErrors,
+		},
 	}
 
 	return Schema{Version: SchemaVersion, Fields: fields}
 }
+
+// bound returns a pointer for FieldMeta.Min and FieldMeta.Max.
+func bound(v float64) *float64 {
+	return &v
+}
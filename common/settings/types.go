@@ -24,14 +24,15 @@ const (
 
 // Settings captures the canonical configuration surface for PrintMaster agents/tenants.
 // Fleet-managed sections: Discovery, SNMP, Features, Spooler
-// Agent-local sections: Logging, Web
+// Agent-local sections: Logging, Web, Notifications
 type Settings struct {
-	Discovery DiscoverySettings `json:"discovery" toml:"discovery"`
-	SNMP      SNMPSettings      `json:"snmp" toml:"snmp"`
-	Features  FeaturesSettings  `json:"features" toml:"features"`
-	Spooler   SpoolerSettings   `json:"spooler" toml:"spooler"`
-	Logging   LoggingSettings   `json:"logging" toml:"logging"`
-	Web       WebSettings       `json:"web" toml:"web"`
+	Discovery     DiscoverySettings    `json:"discovery" toml:"discovery"`
+	SNMP          SNMPSettings         `json:"snmp" toml:"snmp"`
+	Features      FeaturesSettings     `json:"features" toml:"features"`
+	Spooler       SpoolerSettings      `json:"spooler" toml:"spooler"`
+	Logging       LoggingSettings      `json:"logging" toml:"logging"`
+	Web           WebSettings          `json:"web" toml:"web"`
+	Notifications NotificationSettings `json:"notifications" toml:"notifications"`
 }
 
 // DiscoverySettings control how printers are discovered and rescanned (fleet-managed).
@@ -57,6 +58,13 @@ type DiscoverySettings struct {
 	ShowDiscoverButtonAnyway    bool `json:"show_discover_button_anyway"`
 	ShowDiscoveredDevicesAnyway bool `json:"show_discovered_devices_anyway"`
 
+	// Persistence Filters
+	PersistAllowPatterns      string `json:"persist_allow_patterns"`        // Manufacturer/model glob patterns, one per line; empty = store all
+	PersistIgnoreSysObjectIDs string `json:"persist_ignore_sys_object_ids"` // sysObjectID prefixes that are never stored, one per line
+
+	// Authorized Scan Scope
+	AuthorizedScanScope string `json:"authorized_scan_scope"` // CIDRs/IPs the agent may scan, one per line; empty = unrestricted
+
 	// Passive Listeners
 	PassiveDiscoveryEnabled  bool `json:"passive_discovery_enabled"`
 	AutoDiscoverLiveMDNS     bool `json:"auto_discover_live_mdns"`
@@ -64,11 +72,21 @@ type DiscoverySettings struct {
 	AutoDiscoverLiveSSDP     bool `json:"auto_discover_live_ssdp"`
 	AutoDiscoverLiveSNMPTrap bool `json:"auto_discover_live_snmptrap"`
 	AutoDiscoverLiveLLMNR    bool `json:"auto_discover_live_llmnr"`
+	SNMPTrapFallbackPort     int  `json:"snmp_trap_fallback_port"` // Unprivileged UDP port used when 162 cannot be bound
 
 	// Metrics Collection
 	MetricsRescanEnabled         bool `json:"metrics_rescan_enabled"`
 	MetricsRescanIntervalMinutes int  `json:"metrics_rescan_interval_minutes"`
 	MetricsRescanIntervalSeconds int  `json:"metrics_rescan_interval_seconds"` // For sub-minute intervals (takes precedence if set)
+
+	// Service Scan (security posture)
+	ServiceScanEnabled       bool `json:"service_scan_enabled"`        // Opt-in TCP/SNMP service scan of discovered devices
+	ServiceScanMaxPerMinute  int  `json:"service_scan_max_per_minute"` // Maximum devices scanned per minute
+	ServiceScanIntervalHours int  `json:"service_scan_interval_hours"` // Minimum hours between scans of the same device
+
+	// Meter Reads (billing-grade counter certification)
+	MeterReadEnabled    bool `json:"meter_read_enabled"`      // Capture a certified meter read once per month
+	MeterReadDayOfMonth int  `json:"meter_read_day_of_month"` // Day of month (1-28) from which the monthly read is due
 }
 
 // SNMPSettings configure SNMP queries (fleet-managed).
@@ -97,6 +115,15 @@ type SNMPSettings struct {
 	PrivPassword string `json:"priv_password,omitempty"`
 	// ContextName is the SNMPv3 context name (optional)
 	ContextName string `json:"context_name,omitempty"`
+
+	// Community sweep (authorized onboarding only)
+	// CommunitySweepEnabled lets agents try CommunityCandidates against devices
+	// that do not answer the configured community
+	CommunitySweepEnabled bool `json:"community_sweep_enabled"`
+	// CommunityCandidates lists community strings to try, one per line, in order
+	CommunityCandidates string `json:"community_candidates,omitempty"`
+	// CommunitySweepPerSecond caps sweep attempts per second across all devices
+	CommunitySweepPerSecond int `json:"community_sweep_per_second"`
 }
 
 // FeaturesSettings toggle optional features (fleet-managed).
@@ -104,6 +131,10 @@ type FeaturesSettings struct {
 	EpsonRemoteModeEnabled bool   `json:"epson_remote_mode_enabled"`
 	CredentialsEnabled     bool   `json:"credentials_enabled"`
 	AssetIDRegex           string `json:"asset_id_regex"`
+	StatusPageOCREnabled   bool   `json:"status_page_ocr_enabled"`
+	// UnknownDeviceTelemetryEnabled uploads anonymized sysObjectID/sysDescr
+	// samples of printers with no recognized manufacturer
+	UnknownDeviceTelemetryEnabled bool `json:"unknown_device_telemetry_enabled"`
 }
 
 // LoggingSettings configure agent logging (agent-local).
@@ -112,6 +143,14 @@ type LoggingSettings struct {
 	DumpParseDebug bool   `json:"dump_parse_debug"`
 }
 
+// NotificationSettings control native desktop notifications (agent-local).
+// They only take effect when the agent runs interactively, not as a service.
+type NotificationSettings struct {
+	DesktopEnabled bool `json:"desktop_enabled"`
+	ScanComplete   bool `json:"scan_complete"` // Notify when a manual discovery scan finishes
+	DeviceErrors   bool `json:"device_errors"` // Notify when a device reports a critical error
+}
+
 // SpoolerSettings configure local printer tracking via OS print spooler (fleet-managed).
 type SpoolerSettings struct {
 	Enabled                bool `json:"enabled"`
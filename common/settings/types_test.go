@@ -61,3 +61,35 @@ func TestDefaultSchemaIncludesCriticalFields(t *testing.T) {
 		}
 	}
 }
+
+func TestValidateCommunitySweep(t *testing.T) {
+	s := DefaultSettings()
+	s.SNMP.CommunitySweepEnabled = true
+	if issues := Validate(s); len(issues) != 1 || issues[0].Field != "snmp.community_candidates" {
+		t.Fatalf("expected missing candidates issue, got %+v", issues)
+	}
+	s.SNMP.CommunityCandidates = "public\n\n private \npublic\nsecret"
+	if issues := Validate(s); len(issues) != 0 {
+		t.Fatalf("unexpected issues: %+v", issues)
+	}
+	got := CommunityCandidateList(s.SNMP.CommunityCandidates)
+	if len(got) != 3 || got[0] != "public" || got[1] != "private" || got[2] != "secret" {
+		t.Fatalf("unexpected candidate list: %q", got)
+	}
+}
+
+func TestValidateAuthorizedScanScope(t *testing.T) {
+	s := DefaultSettings()
+	s.Discovery.AuthorizedScanScope = "10.1.0.0/16 # site A\n192.168.5.7, 172.16.3.9/24\n"
+	if issues := Validate(s); len(issues) != 0 {
+		t.Fatalf("unexpected issues: %+v", issues)
+	}
+	prefixes, _ := ScanScopePrefixes(s.Discovery.AuthorizedScanScope)
+	if len(prefixes) != 3 || prefixes[1].String() != "192.168.5.7/32" || prefixes[2].String() != "172.16.3.0/24" {
+		t.Fatalf("unexpected prefixes: %v", prefixes)
+	}
+	s.Discovery.AuthorizedScanScope = "10.0.0.0/8\nprinters.local"
+	if issues := Validate(s); len(issues) != 1 || issues[0].Field != "discovery.authorized_scan_scope" {
+		t.Fatalf("expected invalid entry issue, got %+v", issues)
+	}
+}
@@ -1,10 +1,17 @@
 package settings
 
-import "fmt"
+import (
+	"fmt"
+	"strings"
+)
 
-// ValidationError captures a specific constraint violation.
+// ValidationError captures a specific constraint violation. Path is the JSON
+// Pointer of the field within the settings document and Code a stable
+// identifier for the failed check; both are set by schema validation.
 type ValidationError struct {
 	Field   string `json:"field"`
+	Path    string `json:"path,omitempty"`
+	Code    string `json:"code,omitempty"`
 	Message string `json:"message"`
 }
 
@@ -31,6 +38,30 @@ func Sanitize(s *Settings) {
 			s.Discovery.MetricsRescanIntervalSeconds = 300
 		}
 	}
+	// Service scan: 0 means unset, fall back to defaults
+	if s.Discovery.ServiceScanMaxPerMinute <= 0 {
+		s.Discovery.ServiceScanMaxPerMinute = DefaultSettings().Discovery.ServiceScanMaxPerMinute
+	}
+	if s.Discovery.ServiceScanMaxPerMinute > 60 {
+		s.Discovery.ServiceScanMaxPerMinute = 60
+	}
+	if s.Discovery.ServiceScanIntervalHours <= 0 {
+		s.Discovery.ServiceScanIntervalHours = DefaultSettings().Discovery.ServiceScanIntervalHours
+	}
+	if s.Discovery.ServiceScanIntervalHours > 720 {
+		s.Discovery.ServiceScanIntervalHours = 720
+	}
+	// Meter reads: capped at 28 so the read is due in every month
+	if s.Discovery.MeterReadDayOfMonth <= 0 {
+		s.Discovery.MeterReadDayOfMonth = DefaultSettings().Discovery.MeterReadDayOfMonth
+	}
+	if s.Discovery.MeterReadDayOfMonth > 28 {
+		s.Discovery.MeterReadDayOfMonth = 28
+	}
+	// Trap fallback port must be unprivileged, or it would fail like 162
+	if s.Discovery.SNMPTrapFallbackPort < 1024 || s.Discovery.SNMPTrapFallbackPort > 65535 {
+		s.Discovery.SNMPTrapFallbackPort = DefaultSettings().Discovery.SNMPTrapFallbackPort
+	}
 	if s.Discovery.Concurrency < 1 {
 		s.Discovery.Concurrency = 1
 	}
@@ -50,6 +81,12 @@ func Sanitize(s *Settings) {
 	if s.SNMP.Retries > 5 {
 		s.SNMP.Retries = 5
 	}
+	if s.SNMP.CommunitySweepPerSecond <= 0 {
+		s.SNMP.CommunitySweepPerSecond = DefaultSettings().SNMP.CommunitySweepPerSecond
+	}
+	if s.SNMP.CommunitySweepPerSecond > 10 {
+		s.SNMP.CommunitySweepPerSecond = 10
+	}
 	// Web
 	if s.Web.HTTPPort == "" {
 		s.Web.HTTPPort = DefaultSettings().Web.HTTPPort
@@ -66,6 +103,14 @@ func Validate(s Settings) []ValidationError {
 	if s.Discovery.ManualRanges && s.Discovery.RangesText == "" {
 		issues = append(issues, ValidationError{Field: "discovery.ranges_text", Message: "manual ranges enabled but no ranges text provided"})
 	}
+	if candidates := CommunityCandidateList(s.SNMP.CommunityCandidates); len(candidates) > MaxCommunityCandidates {
+		issues = append(issues, ValidationError{Field: "snmp.community_candidates", Message: fmt.Sprintf("at most %d candidate communities allowed", MaxCommunityCandidates)})
+	} else if s.SNMP.CommunitySweepEnabled && len(candidates) == 0 {
+		issues = append(issues, ValidationError{Field: "snmp.community_candidates", Message: "community sweep enabled but no candidate communities provided"})
+	}
+	if _, invalid := ScanScopePrefixes(s.Discovery.AuthorizedScanScope); len(invalid) > 0 {
+		issues = append(issues, ValidationError{Field: "discovery.authorized_scan_scope", Message: "invalid CIDR or IP: " + strings.Join(invalid, ", ")})
+	}
 	if s.Web.EnableHTTPS && (s.Web.CustomCertPath == "" || s.Web.CustomKeyPath == "") {
 		// Allow autogenerated cert paths by leaving empty; warn but not error.
 	}
@@ -96,12 +141,29 @@ func MergeDiscovery(base DiscoverySettings, override DiscoverySettings) Discover
 	result.AutosaveDiscoveredDevices = override.AutosaveDiscoveredDevices
 	result.ShowDiscoverButtonAnyway = override.ShowDiscoverButtonAnyway
 	result.ShowDiscoveredDevicesAnyway = override.ShowDiscoveredDevicesAnyway
+	result.PersistAllowPatterns = override.PersistAllowPatterns
+	result.PersistIgnoreSysObjectIDs = override.PersistIgnoreSysObjectIDs
+	result.AuthorizedScanScope = override.AuthorizedScanScope
+	result.ServiceScanEnabled = override.ServiceScanEnabled
+	if override.ServiceScanMaxPerMinute != 0 {
+		result.ServiceScanMaxPerMinute = override.ServiceScanMaxPerMinute
+	}
+	if override.ServiceScanIntervalHours != 0 {
+		result.ServiceScanIntervalHours = override.ServiceScanIntervalHours
+	}
+	result.MeterReadEnabled = override.MeterReadEnabled
+	if override.MeterReadDayOfMonth != 0 {
+		result.MeterReadDayOfMonth = override.MeterReadDayOfMonth
+	}
 	result.PassiveDiscoveryEnabled = override.PassiveDiscoveryEnabled
 	result.AutoDiscoverLiveMDNS = override.AutoDiscoverLiveMDNS
 	result.AutoDiscoverLiveWSD = override.AutoDiscoverLiveWSD
 	result.AutoDiscoverLiveSSDP = override.AutoDiscoverLiveSSDP
 	result.AutoDiscoverLiveSNMPTrap = override.AutoDiscoverLiveSNMPTrap
 	result.AutoDiscoverLiveLLMNR = override.AutoDiscoverLiveLLMNR
+	if override.SNMPTrapFallbackPort != 0 {
+		result.SNMPTrapFallbackPort = override.SNMPTrapFallbackPort
+	}
 	if override.MetricsRescanIntervalMinutes != 0 {
 		result.MetricsRescanIntervalMinutes = override.MetricsRescanIntervalMinutes
 	}
@@ -120,6 +182,7 @@ func Merge(base Settings, override Settings) Settings {
 	merged.Features = override.Features
 	merged.Logging = override.Logging
 	merged.Web = override.Web
+	merged.Notifications = override.Notifications
 	Sanitize(&merged)
 	return merged
 }
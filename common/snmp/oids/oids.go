@@ -213,3 +213,11 @@ const (
 	KyoceraTotalPrinted = "1.3.6.1.4.1.1347.43.10.1.1.12.1.1"
 	KyoceraStatusInfo   = "1.3.6.1.4.1.1347.43.5.1.1.28.1"
 )
+
+// Duplex counters. Units differ by vendor: HP counts sheets, Canon and
+// Brother count duplex impressions (two per sheet).
+const (
+	HPDuplexSheets     = "1.3.6.1.4.1.11.2.3.9.4.2.1.4.4.6.0"
+	CanonDuplexPages   = "1.3.6.1.4.1.1602.1.1.1.1.1.1.10.0"
+	BrotherDuplexPages = "1.3.6.1.4.1.2435.2.3.9.4.2.1.5.5.10.6.0"
+)
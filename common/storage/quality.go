@@ -0,0 +1,73 @@
+package storage
+
+import (
+	"net"
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+var placeholderSerialRe = regexp.MustCompile(`(?i)^(unknown|none|null|n/?a|not ?available|default|serial ?number|x+|-+|0+)$`)
+
+// IsPseudoSerial reports whether serial is a stand-in key rather than a
+// serial number read from the device: an IP address, a MAC address, a
+// placeholder, or a value with padding or control characters.
+func IsPseudoSerial(serial string) bool {
+	if serial == "" {
+		return true
+	}
+	if CleanSerial(serial) != serial {
+		return true
+	}
+	if net.ParseIP(serial) != nil {
+		return true
+	}
+	if _, err := net.ParseMAC(serial); err == nil {
+		return true
+	}
+	return placeholderSerialRe.MatchString(serial)
+}
+
+// CleanSerial strips surrounding whitespace and non-printable characters.
+func CleanSerial(serial string) string {
+	serial = strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) || r == unicode.ReplacementChar {
+			return -1
+		}
+		return r
+	}, serial)
+	return strings.TrimSpace(serial)
+}
+
+// Parsing gaps reported by ParseGaps.
+const (
+	ParseGapSerial   = "serial"
+	ParseGapModel    = "model"
+	ParseGapToner    = "toner"
+	ParseGapCounters = "counters"
+)
+
+// ParseGaps lists the fields the SNMP parser failed to fill for a device,
+// given its latest page count and toner levels (zero and nil when no
+// metrics were collected). Spooler and USB devices are not parsed from SNMP
+// and never have gaps.
+func ParseGaps(d *Device, pageCount int, tonerLevels map[string]interface{}) []string {
+	if d == nil || d.IsUSB || d.DeviceType == DeviceTypeUSB || d.SourceType == SourceTypeSpooler {
+		return nil
+	}
+	var gaps []string
+	if IsPseudoSerial(d.Serial) {
+		gaps = append(gaps, ParseGapSerial)
+	}
+	model := strings.TrimSpace(d.Model)
+	if model == "" || placeholderSerialRe.MatchString(model) || strings.EqualFold(model, strings.TrimSpace(d.Manufacturer)) {
+		gaps = append(gaps, ParseGapModel)
+	}
+	if len(tonerLevels) == 0 {
+		gaps = append(gaps, ParseGapToner)
+	}
+	if pageCount <= 0 {
+		gaps = append(gaps, ParseGapCounters)
+	}
+	return gaps
+}
@@ -0,0 +1,42 @@
+package storage
+
+import "testing"
+
+func TestIsPseudoSerial(t *testing.T) {
+	t.Parallel()
+
+	for _, s := range []string{"", "10.0.0.5", "fe80::1", "00:11:22:33:44:55", "00-11-22-33-44-55", "Unknown", "N/A", "000000", "XXXX", " CNB123 ", "CNB123\x00"} {
+		if !IsPseudoSerial(s) {
+			t.Errorf("expected %q to be a pseudo serial", s)
+		}
+	}
+	for _, s := range []string{"CNB1234567", "X3E012345", "001122334455AB"} {
+		if IsPseudoSerial(s) {
+			t.Errorf("expected %q to be a real serial", s)
+		}
+	}
+	if got := CleanSerial(" cnb123\x00 "); got != "cnb123" {
+		t.Errorf("CleanSerial: got %q", got)
+	}
+}
+
+func TestParseGaps(t *testing.T) {
+	t.Parallel()
+
+	d := &Device{Serial: "10.0.0.5", Manufacturer: "HP", Model: "HP"}
+	got := ParseGaps(d, 0, nil)
+	if len(got) != 4 {
+		t.Fatalf("expected all gaps, got %v", got)
+	}
+	d = &Device{Serial: "CNB123", Manufacturer: "HP", Model: "LaserJet M404"}
+	if got := ParseGaps(d, 1200, map[string]interface{}{"black": 80}); len(got) != 0 {
+		t.Fatalf("expected no gaps, got %v", got)
+	}
+	if got := ParseGaps(d, 1200, nil); len(got) != 1 || got[0] != ParseGapToner {
+		t.Fatalf("expected toner gap, got %v", got)
+	}
+	d.SourceType = SourceTypeSpooler
+	if got := ParseGaps(d, 0, nil); got != nil {
+		t.Fatalf("spooler devices have no parse gaps, got %v", got)
+	}
+}
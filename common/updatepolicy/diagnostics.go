@@ -0,0 +1,30 @@
+package updatepolicy
+
+import "time"
+
+// Limits applied by agents when collecting failure diagnostics so telemetry
+// stays small enough to upload from constrained sites.
+const (
+	MaxDiagnosticLogLines      = 200
+	MaxDiagnosticInstallerLog  = 64 * 1024
+	MaxDiagnosticLogLineLength = 1024
+)
+
+// FailureDiagnostics is collected by an agent when a self-update fails and
+// attached to the update telemetry it reports, so the server can keep it with
+// the rollout record.
+type FailureDiagnostics struct {
+	CollectedAt   time.Time         `json:"collected_at"`
+	OS            string            `json:"os"`
+	Arch          string            `json:"arch"`
+	GoVersion     string            `json:"go_version,omitempty"`
+	Hostname      string            `json:"hostname,omitempty"`
+	Channel       string            `json:"channel,omitempty"`
+	InstallMethod string            `json:"install_method,omitempty"` // "binary", "msi", "apt", "dnf", "yum"
+	IsService     bool              `json:"is_service"`
+	BinaryPath    string            `json:"binary_path,omitempty"`
+	DiskFreeMB    int64             `json:"disk_free_mb,omitempty"`
+	Environment   map[string]string `json:"environment,omitempty"`
+	LogTail       []string          `json:"log_tail,omitempty"`      // Recent autoupdate log lines, oldest first
+	InstallerLog  string            `json:"installer_log,omitempty"` // Tail of the installer log (MSI) when present
+}
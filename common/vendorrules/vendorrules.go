@@ -0,0 +1,179 @@
+// Package vendorrules defines server-managed device detection rules: a
+// sysObjectID prefix and/or sysDescr pattern that identifies a manufacturer
+// (and optionally the model and the vendor module used for metrics). Admins
+// edit rules on the server; agents receive them with their managed settings
+// and consult them before the built-in detection, so a new device family can
+// be recognized without a new agent build.
+package vendorrules
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Rule identifies devices by sysObjectID prefix and/or sysDescr pattern. When
+// both are set, both must match.
+type Rule struct {
+	ID           int64  `json:"id"`
+	Manufacturer string `json:"manufacturer"`
+	// SysObjectIDPrefix matches the sysObjectID at an arc boundary, e.g.
+	// "1.3.6.1.4.1.55555" matches 1.3.6.1.4.1.55555.1.2 but not 1.3.6.1.4.1.555551
+	SysObjectIDPrefix string `json:"sys_object_id_prefix,omitempty"`
+	// SysDescrRegex is matched case-insensitively against sysDescr
+	SysDescrRegex string `json:"sys_descr_regex,omitempty"`
+	// ModelRegex extracts the model from sysDescr: the first capture group,
+	// or the whole match without groups
+	ModelRegex string `json:"model_regex,omitempty"`
+	// VendorModule names the built-in vendor module used for metrics, e.g.
+	// "HP" for an HP OEM device; empty selects by manufacturer
+	VendorModule string `json:"vendor_module,omitempty"`
+	// Priority orders overlapping rules; higher wins
+	Priority    int    `json:"priority"`
+	Enabled     bool   `json:"enabled"`
+	Description string `json:"description,omitempty"`
+}
+
+// Match is the outcome of a matching rule.
+type Match struct {
+	RuleID       int64
+	Manufacturer string
+	Model        string // Empty when the rule has no ModelRegex or it didn't match
+	VendorModule string
+}
+
+var oidPrefixRe = regexp.MustCompile(`^[0-9]+(\.[0-9]+)*$`)
+
+// Validate checks that a rule is complete and its patterns compile.
+func (r *Rule) Validate() error {
+	r.Manufacturer = strings.TrimSpace(r.Manufacturer)
+	r.SysObjectIDPrefix = strings.Trim(strings.TrimSpace(r.SysObjectIDPrefix), ".")
+	r.SysDescrRegex = strings.TrimSpace(r.SysDescrRegex)
+	r.ModelRegex = strings.TrimSpace(r.ModelRegex)
+	r.VendorModule = strings.TrimSpace(r.VendorModule)
+	if r.Manufacturer == "" {
+		return errors.New("manufacturer is required")
+	}
+	if r.SysObjectIDPrefix == "" && r.SysDescrRegex == "" {
+		return errors.New("a sysObjectID prefix or sysDescr pattern is required")
+	}
+	if r.SysObjectIDPrefix != "" && !oidPrefixRe.MatchString(r.SysObjectIDPrefix) {
+		return fmt.Errorf("invalid sysObjectID prefix %q", r.SysObjectIDPrefix)
+	}
+	if r.SysDescrRegex != "" {
+		if _, err := regexp.Compile("(?i)" + r.SysDescrRegex); err != nil {
+			return fmt.Errorf("invalid sysDescr pattern: %w", err)
+		}
+	}
+	if r.ModelRegex != "" {
+		if _, err := regexp.Compile("(?i)" + r.ModelRegex); err != nil {
+			return fmt.Errorf("invalid model pattern: %w", err)
+		}
+	}
+	return nil
+}
+
+type compiledRule struct {
+	rule     Rule
+	sysDescr *regexp.Regexp
+	model    *regexp.Regexp
+}
+
+// Set is a compiled, ordered list of enabled rules. The zero value and nil
+// match nothing.
+type Set struct {
+	rules []compiledRule
+}
+
+// Compile builds a Set from the enabled, valid rules. Invalid rules are
+// skipped and reported in the returned error alongside the usable Set.
+func Compile(rules []Rule) (*Set, error) {
+	set := &Set{}
+	var errs []error
+	for _, r := range rules {
+		if !r.Enabled {
+			continue
+		}
+		if err := r.Validate(); err != nil {
+			errs = append(errs, fmt.Errorf("rule %d: %w", r.ID, err))
+			continue
+		}
+		c := compiledRule{rule: r}
+		if r.SysDescrRegex != "" {
+			c.sysDescr = regexp.MustCompile("(?i)" + r.SysDescrRegex)
+		}
+		if r.ModelRegex != "" {
+			c.model = regexp.MustCompile("(?i)" + r.ModelRegex)
+		}
+		set.rules = append(set.rules, c)
+	}
+	// Higher priority first, then the more specific prefix, then oldest rule
+	sort.SliceStable(set.rules, func(i, j int) bool {
+		a, b := set.rules[i].rule, set.rules[j].rule
+		if a.Priority != b.Priority {
+			return a.Priority > b.Priority
+		}
+		if len(a.SysObjectIDPrefix) != len(b.SysObjectIDPrefix) {
+			return len(a.SysObjectIDPrefix) > len(b.SysObjectIDPrefix)
+		}
+		return a.ID < b.ID
+	})
+	return set, errors.Join(errs...)
+}
+
+// Len returns the number of usable rules.
+func (s *Set) Len() int {
+	if s == nil {
+		return 0
+	}
+	return len(s.rules)
+}
+
+// Match returns the first rule matching the device, if any.
+func (s *Set) Match(sysObjectID, sysDescr string) (Match, bool) {
+	if s == nil {
+		return Match{}, false
+	}
+	oid := strings.Trim(strings.TrimSpace(sysObjectID), ".")
+	for _, c := range s.rules {
+		if p := c.rule.SysObjectIDPrefix; p != "" && oid != p && !strings.HasPrefix(oid, p+".") {
+			continue
+		}
+		if c.sysDescr != nil && !c.sysDescr.MatchString(sysDescr) {
+			continue
+		}
+		m := Match{RuleID: c.rule.ID, Manufacturer: c.rule.Manufacturer, VendorModule: c.rule.VendorModule}
+		if c.model != nil {
+			if sub := c.model.FindStringSubmatch(sysDescr); len(sub) > 1 {
+				m.Model = strings.TrimSpace(sub[1])
+			} else if len(sub) == 1 {
+				m.Model = strings.TrimSpace(sub[0])
+			}
+		}
+		return m, true
+	}
+	return Match{}, false
+}
+
+// Version returns a stable hash of the enabled rules, used to tell agents
+// when their copy is out of date. It is empty when no rule is enabled.
+func Version(rules []Rule) string {
+	enabled := make([]Rule, 0, len(rules))
+	for _, r := range rules {
+		if r.Enabled {
+			enabled = append(enabled, r)
+		}
+	}
+	if len(enabled) == 0 {
+		return ""
+	}
+	sort.Slice(enabled, func(i, j int) bool { return enabled[i].ID < enabled[j].ID })
+	data, _ := json.Marshal(enabled)
+	h := sha256.Sum256(data)
+	return hex.EncodeToString(h[:])
+}
@@ -0,0 +1,87 @@
+package vendorrules
+
+import "testing"
+
+func TestSetMatch(t *testing.T) {
+	set, err := Compile([]Rule{
+		{ID: 1, Manufacturer: "Acme", SysObjectIDPrefix: ".1.3.6.1.4.1.55555", ModelRegex: `MFP\s+\S+`, Enabled: true},
+		{ID: 2, Manufacturer: "Acme OEM", SysObjectIDPrefix: "1.3.6.1.4.1.55555.7", VendorModule: "HP", Enabled: true},
+		{ID: 3, Manufacturer: "Contoso", SysDescrRegex: `contoso\s+printer`, ModelRegex: `model:\s*(\S+)`, Enabled: true},
+		{ID: 4, Manufacturer: "Disabled", SysDescrRegex: "acme", Enabled: false},
+		{ID: 5, Manufacturer: "Broken", SysDescrRegex: "(", Enabled: true},
+	})
+	if err == nil {
+		t.Fatalf("expected invalid rule to be reported")
+	}
+	if set.Len() != 3 {
+		t.Fatalf("expected 3 usable rules, got %d", set.Len())
+	}
+
+	cases := []struct {
+		oid, descr string
+		wantRule   int64
+		wantModel  string
+	}{
+		{"1.3.6.1.4.1.55555.1.2", "Acme MFP 3000 v2", 1, "MFP 3000"},
+		{".1.3.6.1.4.1.55555.7.1", "Acme", 2, ""},        // longer prefix wins
+		{"1.3.6.1.4.1.555551.1", "Acme MFP 3000", 0, ""}, // not at an arc boundary
+		{"1.3.6.1.4.1.9.1", "CONTOSO Printer model: CP-200", 3, "CP-200"},
+	}
+	for _, c := range cases {
+		m, ok := set.Match(c.oid, c.descr)
+		if c.wantRule == 0 {
+			if ok {
+				t.Fatalf("%s: unexpected match %+v", c.oid, m)
+			}
+			continue
+		}
+		if !ok || m.RuleID != c.wantRule || m.Model != c.wantModel {
+			t.Fatalf("%s: got %+v (ok=%v), want rule %d model %q", c.oid, m, ok, c.wantRule, c.wantModel)
+		}
+	}
+	if m, _ := set.Match("1.3.6.1.4.1.55555.7.1", ""); m.VendorModule != "HP" {
+		t.Fatalf("expected vendor module to carry over, got %+v", m)
+	}
+
+	// Priority overrides prefix specificity
+	set, _ = Compile([]Rule{
+		{ID: 1, Manufacturer: "Specific", SysObjectIDPrefix: "1.3.6.1.4.1.55555.7", Enabled: true},
+		{ID: 2, Manufacturer: "Preferred", SysObjectIDPrefix: "1.3.6.1.4.1.55555", Priority: 10, Enabled: true},
+	})
+	if m, _ := set.Match("1.3.6.1.4.1.55555.7.1", ""); m.Manufacturer != "Preferred" {
+		t.Fatalf("expected priority to win, got %+v", m)
+	}
+
+	var nilSet *Set
+	if _, ok := nilSet.Match("1.3.6.1.4.1.55555", ""); ok {
+		t.Fatalf("nil set must not match")
+	}
+}
+
+func TestRuleValidate(t *testing.T) {
+	for _, r := range []Rule{
+		{SysObjectIDPrefix: "1.3.6.1.4.1.5"},
+		{Manufacturer: "Acme"},
+		{Manufacturer: "Acme", SysObjectIDPrefix: "1.3.x"},
+		{Manufacturer: "Acme", SysDescrRegex: "acme", ModelRegex: "["},
+	} {
+		if err := r.Validate(); err == nil {
+			t.Fatalf("expected %+v to be invalid", r)
+		}
+	}
+	r := Rule{Manufacturer: " Acme ", SysObjectIDPrefix: ".1.3.6.1.4.1.55555."}
+	if err := r.Validate(); err != nil || r.SysObjectIDPrefix != "1.3.6.1.4.1.55555" || r.Manufacturer != "Acme" {
+		t.Fatalf("unexpected normalization: %+v, %v", r, err)
+	}
+}
+
+func TestVersion(t *testing.T) {
+	a := []Rule{{ID: 2, Manufacturer: "B", SysDescrRegex: "b", Enabled: true}, {ID: 1, Manufacturer: "A", SysDescrRegex: "a", Enabled: true}}
+	b := []Rule{a[1], a[0], {ID: 3, Manufacturer: "C", SysDescrRegex: "c"}}
+	if Version(a) == "" || Version(a) != Version(b) {
+		t.Fatalf("version must ignore order and disabled rules")
+	}
+	if Version(nil) != "" {
+		t.Fatalf("expected empty version without rules")
+	}
+}
@@ -576,6 +576,20 @@
                 return;
             }
 
+            if (action === 'print-asset-tag') {
+                const serial = btn.getAttribute('data-serial') || '';
+                const label = btn.getAttribute('data-label') || serial;
+                const win = window.open('', '_blank');
+                if (!win) return;
+                const esc = (v) => String(v).replace(/[&<>"]/g, c => ({ '&': '&amp;', '<': '&lt;', '>': '&gt;', '"': '&quot;' }[c]));
+                win.document.write('<!doctype html><title>Asset tag ' + esc(serial) + '</title>' +
+                    '<body style="font-family:sans-serif;text-align:center;margin:24px">' +
+                    '<img src="/api/devices/qr?serial=' + encodeURIComponent(serial) + '&scale=8" onload="window.print()" style="width:240px;height:240px">' +
+                    '<div style="font-size:14px;margin-top:8px">' + esc(label) + '</div></body>');
+                win.document.close();
+                return;
+            }
+
             // Server-specific actions (agents/devices list)
             if (action === 'view-agent') {
                 const agentId = btn.getAttribute('data-agent-id');
@@ -785,6 +799,21 @@
         deviceInfo += '</div>';
         html += renderInfoCard('Device Info', deviceInfo);
 
+        // QR asset tag (agent UI only; links to the agent's mobile asset page)
+        if (source === 'saved' && p.serial && window.__pm_shared_cards.assetTagsEnabled) {
+            const qrSrc = '/api/devices/qr?serial=' + encodeURIComponent(p.serial);
+            const tagLabel = (p.asset_number ? p.asset_number + ' · ' : '') + p.serial;
+            const tagHtml = '<div style="display:flex;gap:12px;align-items:center">' +
+                '<img src="' + qrSrc + '" alt="QR asset tag" style="width:120px;height:120px;background:#fff;border-radius:4px">' +
+                '<div style="display:flex;flex-direction:column;gap:6px">' +
+                '<div style="color:var(--muted);font-size:12px">Scan to view or update the asset number and location from a phone.</div>' +
+                '<div style="display:flex;gap:6px">' +
+                '<button style="font-size:12px;padding:6px 10px" data-action="print-asset-tag" data-serial="' + p.serial + '" data-label="' + tagLabel.replace(/"/g, '&quot;') + '">Print</button>' +
+                '<a class="btn small" href="' + qrSrc + '&download=1" download>Download SVG</a>' +
+                '</div></div></div>';
+            html += renderInfoCard('Asset Tag', tagHtml);
+        }
+
         // Metrics card for saved devices (compact summary + quick-open buttons)
         if (source === 'saved' && p.serial) {
             const metricsHtml = '<div class="device-metrics-card-content">' +
@@ -807,6 +836,23 @@
     networkInfo += '</div>';
     html += renderInfoCard('Network', networkInfo);
 
+        // Web server fingerprint (HTTP banner + TLS certificate captured during discovery)
+        const webFp = p.web_fingerprint || (p.raw_data && p.raw_data.web_fingerprint) || null;
+        if (webFp) {
+            const fpRow = (label, val) => val ? '<div style="color:var(--muted)">' + label + ':</div><div style="word-break:break-all">' + escapeHtmlCards(String(val)) + '</div>' : '';
+            let fpInfo = '<div style="display:grid;grid-template-columns:auto 1fr;gap:4px 8px">';
+            fpInfo += fpRow('Server', webFp.server);
+            fpInfo += fpRow('Powered By', webFp.powered_by);
+            fpInfo += fpRow('Page Title', webFp.title);
+            fpInfo += fpRow('TLS', webFp.tls_version);
+            fpInfo += fpRow('Cert Subject', webFp.cert_subject);
+            fpInfo += fpRow('Cert Issuer', webFp.cert_issuer ? webFp.cert_issuer + (webFp.cert_self_signed ? ' (self-signed)' : '') : '');
+            if (webFp.cert_not_after) fpInfo += fpRow('Cert Expires', new Date(webFp.cert_not_after).toLocaleDateString());
+            fpInfo += fpRow('SHA-256', webFp.cert_sha256);
+            fpInfo += '</div>';
+            html += renderInfoCard('Web Server', fpInfo);
+        }
+
         // Web UI Credentials Card (for proxy auto-login)
         // Determine default username based on manufacturer
         const mfg = (p.manufacturer || '').toLowerCase();
@@ -1076,6 +1076,17 @@ async function loadUsageGraph(serial) {
     if (!canvas) return;
 
     try {
+        // Prefer the agent's pre-bucketed sparkline (pages/day); fall back to
+        // raw history where it isn't available (e.g. the server UI)
+        const spark = await fetchUsageSparkline(serial);
+        if (spark) {
+            const start = new Date(spark.start + 'T00:00:00').getTime();
+            const points = (spark.pages || []).map((v, i) => ({ t: start + i * 86400000, v: Number(v || 0) }));
+            container.title = usageSparklineTitle(spark);
+            drawUsageSparkline(canvas, points, (spark.total_pages || 0).toLocaleString() + ' pages / ' + spark.days + 'd');
+            return;
+        }
+
         const url = '/api/devices/metrics/history?serial=' + encodeURIComponent(serial) + '&period=month';
         const res = await fetch(url);
         if (!res.ok) {
@@ -1097,7 +1108,33 @@ async function loadUsageGraph(serial) {
     }
 }
 
-function drawUsageSparkline(canvas, points) {
+async function fetchUsageSparkline(serial) {
+    try {
+        const res = await fetch('/api/v1/devices/' + encodeURIComponent(serial) + '/sparkline?days=30');
+        if (!res.ok) return null;
+        const data = await res.json();
+        return data && Array.isArray(data.pages) ? data : null;
+    } catch (e) {
+        return null;
+    }
+}
+
+// Tooltip text for a sparkline: totals plus the latest known toner levels
+function usageSparklineTitle(spark) {
+    const lines = [(spark.total_pages || 0).toLocaleString() + ' pages in the last ' + spark.days + ' days'];
+    Object.keys(spark.toner || {}).sort().forEach(key => {
+        const series = spark.toner[key] || [];
+        for (let i = series.length - 1; i >= 0; i--) {
+            if (series[i] !== null && series[i] !== undefined) {
+                lines.push(key + ': ' + series[i] + '%');
+                break;
+            }
+        }
+    });
+    return lines.join('\n');
+}
+
+function drawUsageSparkline(canvas, points, label) {
     const ctx = canvas.getContext('2d');
     if (!ctx) return;
     // DPI handling
@@ -1158,12 +1195,12 @@ function drawUsageSparkline(canvas, points) {
     ctx.lineWidth = 1.5;
     ctx.stroke();
 
-    // Latest value label
+    // Latest value label (or caller-supplied summary)
     const latest = vals[vals.length - 1] || 0;
     ctx.fillStyle = 'rgba(255,255,255,0.9)';
     ctx.font = '11px monospace';
     ctx.textAlign = 'right';
-    ctx.fillText(latest.toLocaleString(), rect.width - 6, 12);
+    ctx.fillText(label || latest.toLocaleString(), rect.width - 6, 12);
 }
 
 // Export usage loader
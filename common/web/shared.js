@@ -1235,6 +1235,11 @@ window.__pm_shared.deleteAgent = async function (agentId, agentName) {
             window.__pm_shared.showToast('Failed to delete agent: ' + txt, 'error');
             return;
         }
+        if (res.status === 202) {
+            const held = await res.json().catch(() => ({}));
+            window.__pm_shared.showToast(held.message || 'Deletion is waiting for approval', 'info');
+            return;
+        }
         window.__pm_shared.showToast('Agent deleted', 'success');
         // Trigger a refresh if function exists
         if (typeof loadAgents === 'function') try { loadAgents(); } catch (e) {}
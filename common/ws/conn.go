@@ -25,6 +25,12 @@ type Conn struct {
 // tlsCfg may be nil to use default TLS settings.
 // The URL is validated to only allow ws/wss schemes before dialing.
 func Dial(urlStr string, reqHeader http.Header, tlsCfg *tls.Config, handshakeTimeout time.Duration) (*Conn, *http.Response, error) {
+	return DialWithProxy(urlStr, reqHeader, tlsCfg, handshakeTimeout, nil)
+}
+
+// DialWithProxy is Dial with an HTTP proxy selected per request, in the form
+// used by http.Transport.Proxy. A nil proxy function connects direct.
+func DialWithProxy(urlStr string, reqHeader http.Header, tlsCfg *tls.Config, handshakeTimeout time.Duration, proxy func(*http.Request) (*url.URL, error)) (*Conn, *http.Response, error) {
 	// Validate and parse the URL
 	parsed, err := url.Parse(urlStr)
 	if err != nil {
@@ -39,7 +45,7 @@ func Dial(urlStr string, reqHeader http.Header, tlsCfg *tls.Config, handshakeTim
 	// Use the parsed URL string (breaks taint chain for CodeQL)
 	validatedURL := parsed.String()
 
-	dialer := &websocket.Dialer{HandshakeTimeout: handshakeTimeout, TLSClientConfig: tlsCfg}
+	dialer := &websocket.Dialer{HandshakeTimeout: handshakeTimeout, TLSClientConfig: tlsCfg, Proxy: proxy}
 	c, resp, err := dialer.Dial(validatedURL, reqHeader)
 	if err != nil {
 		return nil, resp, err
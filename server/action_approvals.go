@@ -0,0 +1,373 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	authz "printmaster/server/authz"
+	"printmaster/server/storage"
+)
+
+// defaultApprovalExpiryHours applies when security.approval_expiry_hours is unset.
+const defaultApprovalExpiryHours = 24
+
+// Operations held for a second administrator when security.require_approvals
+// is on.
+const (
+	approvalActionAgentDelete        = "agent.delete"
+	approvalActionAgentForceUpdate   = "agent.force_update"
+	approvalActionAgentClearDatabase = "agent.clear_database"
+	approvalActionAgentClearDevices  = "agent.clear_discovered"
+)
+
+// gatedAgentProxyPaths maps agent web UI endpoints that wipe agent data to the
+// approval guarding them when they are called through the agent proxy.
+// Summary is formatted with the agent's display name.
+var gatedAgentProxyPaths = map[string]struct{ Action, Summary string }{
+	"/database/clear":           {approvalActionAgentClearDatabase, "Back up and reset the database of %s"},
+	"/devices/clear_discovered": {approvalActionAgentClearDevices, "Delete all discovered devices on %s"},
+}
+
+// approvalExecutor carries out an approved action. r is the approver's
+// request, used for auditing. The returned string is stored as the result.
+type approvalExecutor func(r *http.Request, a *storage.ActionApproval) (string, error)
+
+var approvalExecutors = map[string]approvalExecutor{
+	approvalActionAgentDelete:        executeAgentDeleteApproval,
+	approvalActionAgentForceUpdate:   executeAgentForceUpdateApproval,
+	approvalActionAgentClearDatabase: executeAgentProxyApproval("/database/clear"),
+	approvalActionAgentClearDevices:  executeAgentProxyApproval("/devices/clear_discovered"),
+}
+
+func approvalsRequired() bool {
+	return serverConfig != nil && serverConfig.Security.RequireApprovals
+}
+
+func approvalTTL() time.Duration {
+	hours := defaultApprovalExpiryHours
+	if serverConfig != nil && serverConfig.Security.ApprovalExpiryHours > 0 {
+		hours = serverConfig.Security.ApprovalExpiryHours
+	}
+	return time.Duration(hours) * time.Hour
+}
+
+// requestActionApproval records a pending approval for a on behalf of the
+// caller. An open request for the same action and target is returned instead
+// of creating a duplicate.
+func requestActionApproval(r *http.Request, a *storage.ActionApproval) (*storage.ActionApproval, error) {
+	ctx := r.Context()
+	principal := getPrincipal(r)
+	if principal == nil || principal.User == nil {
+		return nil, fmt.Errorf("approval requests need a signed-in user")
+	}
+	if _, err := serverStore.ExpireActionApprovals(ctx, time.Now()); err != nil {
+		logWarn("Failed to expire action approvals", "error", err)
+	}
+	open, err := serverStore.ListActionApprovals(ctx, storage.ActionApprovalFilter{
+		Status: storage.ActionApprovalPending, Action: a.Action, TargetID: a.TargetID, Limit: 1,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(open) > 0 {
+		return open[0], nil
+	}
+
+	a.RequestedByID = principal.User.ID
+	a.RequestedBy = principal.User.Username
+	a.ExpiresAt = time.Now().UTC().Add(approvalTTL())
+	if err := serverStore.CreateActionApproval(ctx, a); err != nil {
+		return nil, err
+	}
+	auditActionApproval(r, "approval.request", a, a.Summary)
+	sseHub.Broadcast(SSEEvent{
+		Type: "approval_requested",
+		Data: map[string]interface{}{
+			"id":           a.ID,
+			"action":       a.Action,
+			"summary":      a.Summary,
+			"tenant_id":    a.TenantID,
+			"requested_by": a.RequestedBy,
+		},
+	})
+	return a, nil
+}
+
+// holdForApproval records a and answers 202 with the pending request.
+func holdForApproval(w http.ResponseWriter, r *http.Request, a *storage.ActionApproval) {
+	approval, err := requestActionApproval(r, a)
+	if err != nil {
+		logError("Failed to record action approval", "action", a.Action, "target", a.TargetID, "error", err)
+		http.Error(w, "failed to request approval", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"approval_required": true,
+		"approval":          approval,
+		"message":           fmt.Sprintf("Waiting for a second administrator to approve request #%d", approval.ID),
+	})
+}
+
+// handleActionApprovals lists approval requests visible to the caller.
+// GET /api/v1/action-approvals?status=pending
+func handleActionApprovals(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !authorizeOrReject(w, r, authz.ActionActionApprovalsRead, authz.ResourceRef{}) {
+		return
+	}
+	ctx := r.Context()
+	if _, err := serverStore.ExpireActionApprovals(ctx, time.Now()); err != nil {
+		logWarn("Failed to expire action approvals", "error", err)
+	}
+	filter := storage.ActionApprovalFilter{
+		Status: strings.TrimSpace(r.URL.Query().Get("status")),
+		Limit:  200,
+	}
+	if principal := getPrincipal(r); principal != nil && !principal.IsAdmin() {
+		filter.TenantIDs = principal.AllowedTenantIDs()
+		if len(filter.TenantIDs) == 0 {
+			writeActionApprovals(w, nil)
+			return
+		}
+	}
+	approvals, err := serverStore.ListActionApprovals(ctx, filter)
+	if err != nil {
+		logError("Failed to list action approvals", "error", err)
+		http.Error(w, "failed to list approvals", http.StatusInternalServerError)
+		return
+	}
+	writeActionApprovals(w, approvals)
+}
+
+// handleActionApproval returns or decides a single approval request.
+// GET /api/v1/action-approvals/{id}
+// POST /api/v1/action-approvals/{id}/approve|reject|cancel  Body: {"note": "..."}
+func handleActionApproval(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	rest := strings.TrimPrefix(r.URL.Path, "/api/v1/action-approvals/")
+	idPart, verb, _ := strings.Cut(rest, "/")
+	id, err := strconv.ParseInt(idPart, 10, 64)
+	if err != nil {
+		http.Error(w, "invalid approval id", http.StatusBadRequest)
+		return
+	}
+	if _, err := serverStore.ExpireActionApprovals(ctx, time.Now()); err != nil {
+		logWarn("Failed to expire action approvals", "error", err)
+	}
+	approval, err := serverStore.GetActionApproval(ctx, id)
+	if err != nil {
+		logError("Failed to load action approval", "id", id, "error", err)
+		http.Error(w, "failed to load approval", http.StatusInternalServerError)
+		return
+	}
+	if approval == nil {
+		http.Error(w, "approval not found", http.StatusNotFound)
+		return
+	}
+	resource := authz.ResourceRef{TenantIDs: []string{approval.TenantID}}
+
+	if verb == "" {
+		if r.Method != http.MethodGet {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		if !authorizeOrReject(w, r, authz.ActionActionApprovalsRead, resource) {
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(approval)
+		return
+	}
+	if r.Method != http.MethodPost {
+		http.Error(w, "POST only", http.StatusMethodNotAllowed)
+		return
+	}
+	var req struct {
+		Note string `json:"note"`
+	}
+	if r.ContentLength != 0 {
+		if err := decodeJSONBody(r, &req); err != nil {
+			http.Error(w, "invalid JSON", http.StatusBadRequest)
+			return
+		}
+	}
+	principal := getPrincipal(r)
+	if principal == nil || principal.User == nil {
+		http.Error(w, "unauthenticated", http.StatusUnauthorized)
+		return
+	}
+	isRequester := principal.User.ID == approval.RequestedByID
+
+	var status string
+	switch verb {
+	case "approve":
+		if !authorizeOrReject(w, r, authz.ActionActionApprovalsDecide, resource) {
+			return
+		}
+		if isRequester {
+			http.Error(w, "a different administrator must approve this request", http.StatusForbidden)
+			return
+		}
+		status = storage.ActionApprovalApproved
+	case "reject":
+		if !authorizeOrReject(w, r, authz.ActionActionApprovalsDecide, resource) {
+			return
+		}
+		status = storage.ActionApprovalRejected
+	case "cancel":
+		if !authorizeOrReject(w, r, authz.ActionActionApprovalsRead, resource) {
+			return
+		}
+		if !isRequester && !principal.IsAdmin() {
+			http.Error(w, "only the requester can cancel this request", http.StatusForbidden)
+			return
+		}
+		status = storage.ActionApprovalCancelled
+	default:
+		http.NotFound(w, r)
+		return
+	}
+
+	ok, err := serverStore.DecideActionApproval(ctx, id, status, principal.User.Username, strings.TrimSpace(req.Note))
+	if err != nil {
+		logError("Failed to decide action approval", "id", id, "error", err)
+		http.Error(w, "failed to update approval", http.StatusInternalServerError)
+		return
+	}
+	if !ok {
+		current, _ := serverStore.GetActionApproval(ctx, id)
+		state := approval.Status
+		if current != nil {
+			state = current.Status
+		}
+		http.Error(w, fmt.Sprintf("approval is %s", state), http.StatusConflict)
+		return
+	}
+	auditActionApproval(r, "approval."+verb, approval, strings.TrimSpace(req.Note))
+
+	if status == storage.ActionApprovalApproved {
+		runApprovedAction(r, approval)
+	}
+	approval, _ = serverStore.GetActionApproval(ctx, id)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(approval)
+}
+
+// runApprovedAction carries out an approved request and records the outcome.
+func runApprovedAction(r *http.Request, approval *storage.ActionApproval) {
+	status, result := storage.ActionApprovalExecuted, ""
+	exec, ok := approvalExecutors[approval.Action]
+	if !ok {
+		status, result = storage.ActionApprovalFailed, "unknown action "+approval.Action
+	} else if out, err := exec(r, approval); err != nil {
+		status, result = storage.ActionApprovalFailed, err.Error()
+	} else {
+		result = out
+	}
+	if status == storage.ActionApprovalFailed {
+		logWarn("Approved action failed", "id", approval.ID, "action", approval.Action, "target", approval.TargetID, "error", result)
+	} else {
+		logInfo("Approved action executed", "id", approval.ID, "action", approval.Action, "target", approval.TargetID)
+	}
+	if err := serverStore.CompleteActionApproval(r.Context(), approval.ID, status, result); err != nil {
+		logError("Failed to record approved action result", "id", approval.ID, "error", err)
+	}
+}
+
+func executeAgentDeleteApproval(r *http.Request, a *storage.ActionApproval) (string, error) {
+	if err := removeAgent(r.Context(), a.TargetID); err != nil {
+		return "", err
+	}
+	return "agent deleted", nil
+}
+
+func executeAgentForceUpdateApproval(r *http.Request, a *storage.ActionApproval) (string, error) {
+	agent, err := serverStore.GetAgent(r.Context(), a.TargetID)
+	if err != nil || agent == nil {
+		return "", fmt.Errorf("agent not found")
+	}
+	data, _ := a.Payload["data"].(map[string]interface{})
+	if err := sendAgentCommand(agent.AgentID, "force_update", data); err != nil {
+		return "", err
+	}
+	meta := metadataWithCommandPayload(data)
+	if reason := getCommandStringField(data, "reason"); reason != "" {
+		meta["reason"] = reason
+	}
+	meta["approval_id"] = a.ID
+	meta["requested_by"] = a.RequestedBy
+	logAgentUpdateAuditFromRequest(r, agent, "agent.update.force",
+		fmt.Sprintf("Forced reinstall triggered for %s", displayNameForAgent(agent)), meta)
+	return "force_update sent", nil
+}
+
+// executeAgentProxyApproval replays an approved POST to an agent web UI
+// endpoint through the agent's WebSocket.
+func executeAgentProxyApproval(path string) approvalExecutor {
+	return func(r *http.Request, a *storage.ActionApproval) (string, error) {
+		if !isAgentConnectedWS(a.TargetID) {
+			return "", errAgentNotConnected
+		}
+		agentR, _ := http.NewRequest(http.MethodPost, path, nil)
+		agentR.Header.Set("X-PrintMaster-Server-Request", "true")
+		capture := &responseCapture{headers: make(http.Header)}
+		proxyThroughWebSocketWithTimeout(capture, agentR, a.TargetID, "http://localhost:8080"+path, 60*time.Second)
+		body := strings.TrimSpace(capture.body.String())
+		if capture.statusCode != 0 && capture.statusCode != http.StatusOK {
+			return "", fmt.Errorf("agent returned %d: %s", capture.statusCode, body)
+		}
+		return body, nil
+	}
+}
+
+func writeActionApprovals(w http.ResponseWriter, approvals []*storage.ActionApproval) {
+	if approvals == nil {
+		approvals = []*storage.ActionApproval{}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(approvals)
+}
+
+func auditActionApproval(r *http.Request, action string, a *storage.ActionApproval, details string) {
+	actorType, actorID, actorName, actorTenant := auditActorFromPrincipal(r)
+	logInfo("Action approval change", "action", action, "id", a.ID, "approval_action", a.Action, "target", a.TargetID, "actor", actorName)
+	logAuditEntry(r.Context(), &storage.AuditEntry{
+		ActorType:  actorType,
+		ActorID:    actorID,
+		ActorName:  actorName,
+		TenantID:   actorTenant,
+		Action:     action,
+		TargetType: "action_approval",
+		TargetID:   strconv.FormatInt(a.ID, 10),
+		Details:    strings.TrimSpace(a.Action + " " + a.TargetID + " " + details),
+		Metadata: map[string]interface{}{
+			"approval_action": a.Action,
+			"target_type":     a.TargetType,
+			"target_id":       a.TargetID,
+			"requested_by":    a.RequestedBy,
+		},
+		IPAddress: extractClientIP(r),
+		UserAgent: r.Header.Get("User-Agent"),
+	})
+}
+
+// agentApproval describes a gated operation on agent.
+func agentApproval(action string, agent *storage.Agent, summary string) *storage.ActionApproval {
+	return &storage.ActionApproval{
+		Action:     action,
+		TenantID:   agent.TenantID,
+		TargetType: "agent",
+		TargetID:   agent.AgentID,
+		TargetName: displayNameForAgent(agent),
+		Summary:    summary,
+	}
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"printmaster/common/logger"
+	"printmaster/server/storage"
+)
+
+func TestActionApprovalsFourEyes(t *testing.T) {
+	prevConfig, prevLogger := serverConfig, serverLogger
+	t.Cleanup(func() { serverConfig, serverLogger = prevConfig, prevLogger })
+	serverLogger = logger.New(logger.ERROR, "", 10)
+	serverConfig = DefaultConfig()
+	serverConfig.Security.RequireApprovals = true
+
+	store := SetupTestStore(t)
+	ctx := context.Background()
+	for _, a := range []*storage.Agent{
+		{AgentID: "agent-a", Hostname: "a", Token: "ta", TenantID: "tenant-a", Status: "active", RegisteredAt: time.Now(), LastSeen: time.Now()},
+		{AgentID: "agent-b", Hostname: "b", Token: "tb", TenantID: "tenant-b", Status: "active", RegisteredAt: time.Now(), LastSeen: time.Now()},
+	} {
+		if err := store.RegisterAgent(ctx, a); err != nil {
+			t.Fatalf("RegisterAgent: %v", err)
+		}
+	}
+
+	operator := NewTestUser(storage.RoleOperator, "tenant-a")
+	admin := NewTestAdminUser()
+	secondAdmin := NewTestAdminUser()
+	secondAdmin.ID, secondAdmin.Username = admin.ID+1, "second-admin"
+
+	do := func(user *storage.User, method, path string, body interface{}, h http.HandlerFunc) *httptest.ResponseRecorder {
+		var raw []byte
+		if body != nil {
+			raw, _ = json.Marshal(body)
+		}
+		req := InjectTestUser(httptest.NewRequest(method, path, bytes.NewReader(raw)), user)
+		rr := httptest.NewRecorder()
+		h(rr, req)
+		return rr
+	}
+	decode := func(rr *httptest.ResponseRecorder) *storage.ActionApproval {
+		var resp struct {
+			ApprovalRequired bool                    `json:"approval_required"`
+			Approval         *storage.ActionApproval `json:"approval"`
+		}
+		if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil || !resp.ApprovalRequired || resp.Approval == nil {
+			t.Fatalf("expected pending approval, got %d %s", rr.Code, rr.Body.String())
+		}
+		return resp.Approval
+	}
+
+	// Deleting an agent is held, and repeating the request reuses the approval
+	rr := do(operator, http.MethodDelete, "/api/v1/agents/agent-a", nil, handleAgentDetails)
+	if rr.Code != http.StatusAccepted {
+		t.Fatalf("expected 202, got %d: %s", rr.Code, rr.Body.String())
+	}
+	deletion := decode(rr)
+	if deletion.Action != approvalActionAgentDelete || deletion.TenantID != "tenant-a" || deletion.RequestedBy != operator.Username {
+		t.Fatalf("unexpected approval: %+v", deletion)
+	}
+	if again := decode(do(operator, http.MethodDelete, "/api/v1/agents/agent-a", nil, handleAgentDetails)); again.ID != deletion.ID {
+		t.Fatalf("expected duplicate request to reuse approval %d, got %d", deletion.ID, again.ID)
+	}
+	if agent, _ := store.GetAgent(ctx, "agent-a"); agent == nil {
+		t.Fatal("agent deleted before approval")
+	}
+
+	approvePath := fmt.Sprintf("/api/v1/action-approvals/%d/approve", deletion.ID)
+	if rr := do(operator, http.MethodPost, approvePath, nil, handleActionApproval); rr.Code != http.StatusForbidden {
+		t.Fatalf("expected operator approval to be forbidden, got %d", rr.Code)
+	}
+	rr = do(admin, http.MethodPost, approvePath, map[string]string{"note": "decommissioned"}, handleActionApproval)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("approve: %d %s", rr.Code, rr.Body.String())
+	}
+	var decided storage.ActionApproval
+	json.Unmarshal(rr.Body.Bytes(), &decided)
+	if decided.Status != storage.ActionApprovalExecuted || decided.DecidedBy != admin.Username || decided.DecisionNote != "decommissioned" {
+		t.Fatalf("unexpected decided approval: %+v", decided)
+	}
+	if agent, _ := store.GetAgent(ctx, "agent-a"); agent != nil {
+		t.Fatal("expected agent to be deleted after approval")
+	}
+	if rr := do(secondAdmin, http.MethodPost, approvePath, nil, handleActionApproval); rr.Code != http.StatusConflict {
+		t.Fatalf("expected 409 approving a decided request, got %d", rr.Code)
+	}
+
+	// Admins cannot approve their own requests
+	rr = do(admin, http.MethodPost, "/api/v1/agents/command/agent-b", map[string]interface{}{"command": "force_update", "data": map[string]interface{}{"reason": "corrupt install"}}, handleAgentCommand)
+	if rr.Code != http.StatusAccepted {
+		t.Fatalf("expected 202 for force_update, got %d: %s", rr.Code, rr.Body.String())
+	}
+	reinstall := decode(rr)
+	if reinstall.Reason != "corrupt install" {
+		t.Fatalf("expected reason to be kept, got %+v", reinstall)
+	}
+	path := fmt.Sprintf("/api/v1/action-approvals/%d", reinstall.ID)
+	if rr := do(admin, http.MethodPost, path+"/approve", nil, handleActionApproval); rr.Code != http.StatusForbidden {
+		t.Fatalf("expected self-approval to be forbidden, got %d", rr.Code)
+	}
+	if rr := do(secondAdmin, http.MethodPost, path+"/reject", nil, handleActionApproval); rr.Code != http.StatusOK {
+		t.Fatalf("reject: %d %s", rr.Code, rr.Body.String())
+	}
+
+	// Operators only see their tenants' requests
+	rr = do(operator, http.MethodGet, "/api/v1/action-approvals", nil, handleActionApprovals)
+	var listed []*storage.ActionApproval
+	json.Unmarshal(rr.Body.Bytes(), &listed)
+	if rr.Code != http.StatusOK || len(listed) != 1 || listed[0].ID != deletion.ID {
+		t.Fatalf("unexpected operator list: %d %s", rr.Code, rr.Body.String())
+	}
+	rr = do(admin, http.MethodGet, "/api/v1/action-approvals?status=rejected", nil, handleActionApprovals)
+	json.Unmarshal(rr.Body.Bytes(), &listed)
+	if len(listed) != 1 || listed[0].ID != reinstall.ID {
+		t.Fatalf("unexpected rejected list: %s", rr.Body.String())
+	}
+}
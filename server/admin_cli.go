@@ -0,0 +1,835 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/user"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"printmaster/common/config"
+	"printmaster/common/logger"
+	"printmaster/server/relay"
+	"printmaster/server/storage"
+)
+
+// Exit codes for the admin CLI.
+const (
+	adminExitOK    = 0
+	adminExitError = 1
+	adminExitUsage = 2
+)
+
+// errAdminUsage marks errors caused by invalid arguments.
+var errAdminUsage = errors.New("invalid usage")
+
+// adminAction implements one "admin <resource> <action>" command. fs is
+// already parsed and env holds the opened store.
+type adminAction struct {
+	usage string
+	flags func(fs *flag.FlagSet) func(env *adminEnv) error
+}
+
+// adminCommands lists the admin CLI commands by resource and action. They
+// work directly on the server database, so they are usable for automation
+// and for recovering access when the web UI is unreachable.
+var adminCommands = map[string]map[string]adminAction{
+	"tenant": {
+		"list":   {usage: "tenant list", flags: adminTenantList},
+		"create": {usage: "tenant create --name NAME [--id ID] [--description TEXT] [--login-domain DOMAIN] [--contact-email EMAIL] [--kind msp_customer|internal]", flags: adminTenantCreate},
+	},
+	"user": {
+		"list":           {usage: "user list", flags: adminUserList},
+		"create":         {usage: "user create --username NAME [--role admin|tenant_admin|operator|auditor|viewer] [--tenant ID]... [--email EMAIL] [--password PASS | --password-stdin]", flags: adminUserCreate},
+		"reset-password": {usage: "user reset-password --username NAME [--password PASS | --password-stdin]", flags: adminUserResetPassword},
+		"set-role":       {usage: "user set-role --username NAME --role admin|tenant_admin|operator|auditor|viewer", flags: adminUserSetRole},
+		"delete":         {usage: "user delete --username NAME", flags: adminUserDelete},
+	},
+	"join-token": {
+		"list":   {usage: "join-token list [--tenant ID]", flags: adminJoinTokenList},
+		"create": {usage: "join-token create --tenant ID [--ttl-minutes N] [--one-time]", flags: adminJoinTokenCreate},
+		"revoke": {usage: "join-token revoke --id ID", flags: adminJoinTokenRevoke},
+	},
+	"api-token": {
+		"list":   {usage: "api-token list [--username NAME]", flags: adminAPITokenList},
+		"create": {usage: "api-token create --username NAME [--name LABEL] [--ttl-days N]", flags: adminAPITokenCreate},
+		"revoke": {usage: "api-token revoke --id ID", flags: adminAPITokenRevoke},
+	},
+	"agent": {
+		"list": {usage: "agent list [--tenant ID]", flags: adminAgentList},
+		"show": {usage: "agent show --id AGENT_ID", flags: adminAgentShow},
+	},
+	"relay": {
+		"list":   {usage: "relay list", flags: adminRelayList},
+		"create": {usage: "relay create --name NAME --url URL [--region REGION]", flags: adminRelayCreate},
+		"delete": {usage: "relay delete --id ID", flags: adminRelayDelete},
+	},
+}
+
+// adminEnv is what an admin action runs against.
+type adminEnv struct {
+	ctx    context.Context
+	store  storage.Store
+	cfg    *Config
+	json   bool
+	stdin  io.Reader
+	stdout io.Writer
+	stderr io.Writer
+}
+
+// runAdminCommand runs "admin <resource> <action> [flags]" and returns the
+// process exit code.
+func runAdminCommand(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	if len(args) < 2 || strings.HasPrefix(args[0], "-") || strings.HasPrefix(args[1], "-") {
+		printAdminUsage(stderr)
+		return adminExitUsage
+	}
+	actions, ok := adminCommands[args[0]]
+	if !ok {
+		fmt.Fprintf(stderr, "unknown resource %q\n\n", args[0])
+		printAdminUsage(stderr)
+		return adminExitUsage
+	}
+	action, ok := actions[args[1]]
+	if !ok {
+		fmt.Fprintf(stderr, "unknown action %q for %s\n\n", args[1], args[0])
+		printAdminUsage(stderr)
+		return adminExitUsage
+	}
+
+	fs := flag.NewFlagSet("admin "+args[0]+" "+args[1], flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	configPath := fs.String("config", "config.toml", "Configuration file path")
+	dbPath := fs.String("db", "", "SQLite database path (default: from config or the server data directory)")
+	asJSON := fs.Bool("json", false, "Print JSON instead of a table")
+	verbose := fs.Bool("verbose", false, "Write server logs to stderr")
+	run := action.flags(fs)
+	fs.Usage = func() {
+		fmt.Fprintf(stderr, "Usage: printmaster-server admin %s\n\nFlags:\n", action.usage)
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args[2:]); err != nil {
+		return adminExitUsage
+	}
+
+	store, cfg, err := openAdminStore(*configPath, *dbPath, *verbose, stderr)
+	if err != nil {
+		fmt.Fprintf(stderr, "admin: %v\n", err)
+		return adminExitError
+	}
+	defer store.Close()
+	// Audit entries and shared helpers write through the global store
+	serverStore = store
+	serverConfig = cfg
+
+	env := &adminEnv{ctx: context.Background(), store: store, cfg: cfg, json: *asJSON, stdin: stdin, stdout: stdout, stderr: stderr}
+	if err := run(env); err != nil {
+		fmt.Fprintf(stderr, "admin %s %s: %v\n", args[0], args[1], err)
+		if errors.Is(err, errAdminUsage) {
+			fs.Usage()
+			return adminExitUsage
+		}
+		return adminExitError
+	}
+	return adminExitOK
+}
+
+func printAdminUsage(w io.Writer) {
+	fmt.Fprintln(w, "Usage: printmaster-server admin <resource> <action> [flags]")
+	fmt.Fprintln(w, "\nCommands:")
+	resources := make([]string, 0, len(adminCommands))
+	for r := range adminCommands {
+		resources = append(resources, r)
+	}
+	sort.Strings(resources)
+	for _, r := range resources {
+		actions := make([]string, 0, len(adminCommands[r]))
+		for a := range adminCommands[r] {
+			actions = append(actions, a)
+		}
+		sort.Strings(actions)
+		for _, a := range actions {
+			fmt.Fprintf(w, "  %s\n", adminCommands[r][a].usage)
+		}
+	}
+	fmt.Fprintln(w, "\nCommon flags: --config PATH, --db PATH, --json, --verbose")
+}
+
+// openAdminStore loads configuration and opens the database the way
+// runServer does, without starting any server components.
+func openAdminStore(configFlag, dbOverride string, verbose bool, stderr io.Writer) (storage.Store, *Config, error) {
+	level := logger.WARN
+	if verbose {
+		level = logger.INFO
+	}
+	serverLogger = logger.NewWithComponent(level, "", "server", 100)
+	serverLogger.SetConsoleOutput(false)
+	serverLogger.SetOnLogCallback(func(entry logger.LogEntry) {
+		fmt.Fprintf(stderr, "%s [%s] %s %v\n", entry.Timestamp.Format(time.RFC3339), logger.LevelToString(entry.Level), entry.Message, entry.Context)
+	})
+	storage.SetLogger(serverLogger)
+
+	cfg := loadAdminConfig(configFlag)
+	config.ApplyDatabaseEnvOverrides(&cfg.Database, "SERVER")
+	if dbOverride != "" {
+		cfg.Database.Driver = "sqlite"
+		cfg.Database.Path = dbOverride
+	}
+	driver := strings.ToLower(cfg.Database.EffectiveDriver())
+	if driver == "sqlite" || driver == "sqlite3" || driver == "modernc" || driver == "modernc-sqlite" {
+		if cfg.Database.Path == "" {
+			cfg.Database.Path = storage.GetDefaultDBPath()
+			if runtime.GOOS == "windows" {
+				// Interactive servers on Windows keep their database per user
+				if userDir, err := config.GetDataDirectory("server", false); err == nil {
+					if p := filepath.Join(userDir, "server.db"); fileExists(p) && !fileExists(cfg.Database.Path) {
+						cfg.Database.Path = p
+					}
+				}
+			}
+		} else if fi, err := os.Stat(cfg.Database.Path); err == nil && fi.IsDir() {
+			cfg.Database.Path = filepath.Join(cfg.Database.Path, "server.db")
+		}
+		if !fileExists(cfg.Database.Path) {
+			return nil, nil, fmt.Errorf("database %s not found (use --db or --config)", cfg.Database.Path)
+		}
+	}
+
+	store, err := storage.NewStore(&cfg.Database)
+	if err != nil {
+		return nil, nil, fmt.Errorf("open database: %w", err)
+	}
+	return store, cfg, nil
+}
+
+// loadAdminConfig resolves config.toml like runServer: explicit flag/env path
+// first, then the executable directory, the working directory and the
+// service location.
+func loadAdminConfig(configFlag string) *Config {
+	paths := []string{}
+	if resolved := config.ResolveConfigPath("SERVER", configFlag); resolved != "" {
+		paths = append(paths, resolved)
+	}
+	paths = append(paths, filepath.Join(filepath.Dir(os.Args[0]), "config.toml"), "config.toml")
+	if runtime.GOOS == "windows" {
+		programData := os.Getenv("PROGRAMDATA")
+		if programData == "" {
+			programData = "C:\\ProgramData"
+		}
+		paths = append(paths, filepath.Join(programData, "PrintMaster", "server", "config.toml"))
+	}
+	for _, p := range paths {
+		if !fileExists(p) {
+			continue
+		}
+		if cfg, _, err := LoadConfig(p); err == nil {
+			return cfg
+		}
+	}
+	cfg := DefaultConfig()
+	applyEnvOverrides(cfg, newConfigSourceTracker())
+	return cfg
+}
+
+func fileExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
+
+// audit records a change made from the CLI, attributed to the OS user.
+func (e *adminEnv) audit(action, targetType, targetID, tenantID, details string) {
+	actor := "cli"
+	if u, err := user.Current(); err == nil && u.Username != "" {
+		actor = u.Username
+	}
+	logAuditEntry(e.ctx, &storage.AuditEntry{
+		ActorType:  storage.AuditActorSystem,
+		ActorID:    "admin-cli",
+		ActorName:  actor,
+		Action:     action,
+		TargetType: targetType,
+		TargetID:   targetID,
+		TenantID:   tenantID,
+		Details:    details,
+	})
+}
+
+// print writes value as JSON, or header and rows as an aligned table.
+func (e *adminEnv) print(value interface{}, header []string, rows [][]string) error {
+	if e.json {
+		enc := json.NewEncoder(e.stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(value)
+	}
+	tw := tabwriter.NewWriter(e.stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, strings.ToUpper(strings.Join(header, "\t")))
+	for _, row := range rows {
+		fmt.Fprintln(tw, strings.Join(row, "\t"))
+	}
+	return tw.Flush()
+}
+
+// stringsFlag is a repeatable, comma-separated flag value.
+type stringsFlag []string
+
+func (s *stringsFlag) String() string { return strings.Join(*s, ",") }
+
+func (s *stringsFlag) Set(v string) error {
+	for _, part := range strings.Split(v, ",") {
+		if part = strings.TrimSpace(part); part != "" {
+			*s = append(*s, part)
+		}
+	}
+	return nil
+}
+
+func required(name, value string) error {
+	if strings.TrimSpace(value) == "" {
+		return fmt.Errorf("--%s is required: %w", name, errAdminUsage)
+	}
+	return nil
+}
+
+func adminTime(t time.Time) string {
+	if t.IsZero() {
+		return "-"
+	}
+	return t.UTC().Format(time.RFC3339)
+}
+
+func adminTenantList(fs *flag.FlagSet) func(*adminEnv) error {
+	return func(e *adminEnv) error {
+		tenants, err := e.store.ListTenants(e.ctx)
+		if err != nil {
+			return err
+		}
+		if tenants == nil {
+			tenants = []*storage.Tenant{}
+		}
+		rows := make([][]string, 0, len(tenants))
+		for _, t := range tenants {
+			rows = append(rows, []string{t.ID, t.Name, t.LoginDomain, t.ContactEmail, adminTime(t.CreatedAt)})
+		}
+		return e.print(tenants, []string{"id", "name", "login_domain", "contact_email", "created_at"}, rows)
+	}
+}
+
+func adminTenantCreate(fs *flag.FlagSet) func(*adminEnv) error {
+	id := fs.String("id", "", "Tenant ID (default: generated)")
+	name := fs.String("name", "", "Tenant name")
+	description := fs.String("description", "", "Description")
+	loginDomain := fs.String("login-domain", "", "Email domain whose users sign in to this tenant")
+	contactEmail := fs.String("contact-email", "", "Contact email")
+	kind := fs.String("kind", "", "Tenant kind: msp_customer or internal (selects the default dashboard)")
+	return func(e *adminEnv) error {
+		if err := required("name", *name); err != nil {
+			return err
+		}
+		tenantKind, ok := storage.NormalizeTenantKind(*kind)
+		if !ok {
+			return fmt.Errorf("--kind must be msp_customer or internal: %w", errAdminUsage)
+		}
+		t := &storage.Tenant{
+			ID:           strings.TrimSpace(*id),
+			Name:         strings.TrimSpace(*name),
+			Description:  *description,
+			ContactEmail: *contactEmail,
+			LoginDomain:  storage.NormalizeTenantDomain(*loginDomain),
+			Kind:         tenantKind,
+		}
+		if err := e.store.CreateTenant(e.ctx, t); err != nil {
+			return err
+		}
+		e.audit("tenant.create", "tenant", t.ID, t.ID, fmt.Sprintf("Tenant %s created from the admin CLI", t.Name))
+		return e.print(t, []string{"id", "name"}, [][]string{{t.ID, t.Name}})
+	}
+}
+
+func adminUserList(fs *flag.FlagSet) func(*adminEnv) error {
+	return func(e *adminEnv) error {
+		users, err := e.store.ListUsers(e.ctx)
+		if err != nil {
+			return err
+		}
+		if users == nil {
+			users = []*storage.User{}
+		}
+		rows := make([][]string, 0, len(users))
+		for _, u := range users {
+			rows = append(rows, []string{strconv.FormatInt(u.ID, 10), u.Username, string(u.Role), strings.Join(userTenants(u), ","), u.Email})
+		}
+		return e.print(users, []string{"id", "username", "role", "tenants", "email"}, rows)
+	}
+}
+
+func userTenants(u *storage.User) []string {
+	if len(u.TenantIDs) > 0 {
+		return u.TenantIDs
+	}
+	if u.TenantID != "" {
+		return []string{u.TenantID}
+	}
+	return nil
+}
+
+// passwordFlags registers --password and --password-stdin and returns a
+// function resolving the password to set. With neither flag a random
+// password is generated and reported back.
+func passwordFlags(fs *flag.FlagSet) func(e *adminEnv) (password string, generated bool, err error) {
+	pass := fs.String("password", "", "New password (visible in shell history; prefer --password-stdin)")
+	fromStdin := fs.Bool("password-stdin", false, "Read the password from the first line of stdin")
+	return func(e *adminEnv) (string, bool, error) {
+		password := *pass
+		if *fromStdin {
+			if password != "" {
+				return "", false, fmt.Errorf("--password and --password-stdin are exclusive: %w", errAdminUsage)
+			}
+			line, err := bufio.NewReader(e.stdin).ReadString('\n')
+			if err != nil && !errors.Is(err, io.EOF) {
+				return "", false, fmt.Errorf("read password: %w", err)
+			}
+			password = strings.TrimRight(line, "\r\n")
+		}
+		generated := false
+		if password == "" {
+			var err error
+			if password, err = generateAdminPassword(); err != nil {
+				return "", false, err
+			}
+			generated = true
+		}
+		if e.cfg != nil {
+			if err := e.cfg.Security.ValidatePassword(password); err != nil {
+				return "", false, err
+			}
+		}
+		return password, generated, nil
+	}
+}
+
+// generateAdminPassword returns a random password that satisfies any
+// combination of the configurable password requirements.
+func generateAdminPassword() (string, error) {
+	b := make([]byte, 18)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b) + "-Aa1", nil
+}
+
+func lookupUser(e *adminEnv, username string) (*storage.User, error) {
+	if err := required("username", username); err != nil {
+		return nil, err
+	}
+	u, err := e.store.GetUserByUsername(e.ctx, strings.TrimSpace(username))
+	if errors.Is(err, sql.ErrNoRows) || (err == nil && u == nil) {
+		return nil, fmt.Errorf("user %q not found", username)
+	}
+	return u, err
+}
+
+func parseRole(value string) (storage.Role, error) {
+	switch strings.ToLower(strings.TrimSpace(value)) {
+	case string(storage.RoleAdmin), string(storage.RoleTenantAdmin), string(storage.RoleOperator),
+		string(storage.RoleAuditor), string(storage.RoleViewer):
+		return storage.NormalizeRole(value), nil
+	}
+	return "", fmt.Errorf("unknown role %q (use admin, tenant_admin, operator, auditor or viewer): %w", value, errAdminUsage)
+}
+
+func adminUserCreate(fs *flag.FlagSet) func(*adminEnv) error {
+	username := fs.String("username", "", "Username")
+	role := fs.String("role", "viewer", "Role: admin, tenant_admin, operator, auditor or viewer")
+	email := fs.String("email", "", "Email address")
+	var tenants stringsFlag
+	fs.Var(&tenants, "tenant", "Tenant ID the user belongs to (repeatable; none = all tenants)")
+	password := passwordFlags(fs)
+	return func(e *adminEnv) error {
+		if err := required("username", *username); err != nil {
+			return err
+		}
+		r, err := parseRole(*role)
+		if err != nil {
+			return err
+		}
+		pass, generated, err := password(e)
+		if err != nil {
+			return err
+		}
+		u := &storage.User{Username: strings.TrimSpace(*username), Role: r, Email: strings.TrimSpace(*email), TenantIDs: storage.SortTenantIDs(tenants)}
+		if len(u.TenantIDs) > 0 {
+			u.TenantID = u.TenantIDs[0]
+		}
+		if err := e.store.CreateUser(e.ctx, u, pass); err != nil {
+			return err
+		}
+		e.audit("user.create", "user", strconv.FormatInt(u.ID, 10), u.TenantID, fmt.Sprintf("User %s created from the admin CLI with role %s", u.Username, u.Role))
+		return e.printCredential(u.Username, pass, generated)
+	}
+}
+
+func (e *adminEnv) printCredential(username, password string, generated bool) error {
+	if !generated {
+		return e.print(map[string]string{"username": username}, []string{"username"}, [][]string{{username}})
+	}
+	return e.print(map[string]string{"username": username, "password": password}, []string{"username", "password"}, [][]string{{username, password}})
+}
+
+func adminUserResetPassword(fs *flag.FlagSet) func(*adminEnv) error {
+	username := fs.String("username", "", "Username")
+	password := passwordFlags(fs)
+	return func(e *adminEnv) error {
+		u, err := lookupUser(e, *username)
+		if err != nil {
+			return err
+		}
+		pass, generated, err := password(e)
+		if err != nil {
+			return err
+		}
+		if err := e.store.UpdateUserPassword(e.ctx, u.ID, pass); err != nil {
+			return err
+		}
+		e.audit("user.password_reset", "user", strconv.FormatInt(u.ID, 10), u.TenantID, fmt.Sprintf("Password for %s reset from the admin CLI", u.Username))
+		return e.printCredential(u.Username, pass, generated)
+	}
+}
+
+func adminUserSetRole(fs *flag.FlagSet) func(*adminEnv) error {
+	username := fs.String("username", "", "Username")
+	role := fs.String("role", "", "Role: admin, tenant_admin, operator, auditor or viewer")
+	return func(e *adminEnv) error {
+		u, err := lookupUser(e, *username)
+		if err != nil {
+			return err
+		}
+		r, err := parseRole(*role)
+		if err != nil {
+			return err
+		}
+		previous := u.Role
+		u.Role = r
+		if err := e.store.UpdateUser(e.ctx, u); err != nil {
+			return err
+		}
+		e.audit("user.update", "user", strconv.FormatInt(u.ID, 10), u.TenantID, fmt.Sprintf("Role for %s changed from %s to %s from the admin CLI", u.Username, previous, r))
+		return e.print(u, []string{"username", "role"}, [][]string{{u.Username, string(u.Role)}})
+	}
+}
+
+func adminUserDelete(fs *flag.FlagSet) func(*adminEnv) error {
+	username := fs.String("username", "", "Username")
+	return func(e *adminEnv) error {
+		u, err := lookupUser(e, *username)
+		if err != nil {
+			return err
+		}
+		if err := e.store.DeleteUser(e.ctx, u.ID); err != nil {
+			return err
+		}
+		e.audit("user.delete", "user", strconv.FormatInt(u.ID, 10), u.TenantID, fmt.Sprintf("User %s deleted from the admin CLI", u.Username))
+		fmt.Fprintf(e.stderr, "Deleted user %s\n", u.Username)
+		return nil
+	}
+}
+
+func adminJoinTokenList(fs *flag.FlagSet) func(*adminEnv) error {
+	tenant := fs.String("tenant", "", "Only tokens for this tenant")
+	return func(e *adminEnv) error {
+		tenantIDs := []string{strings.TrimSpace(*tenant)}
+		if tenantIDs[0] == "" {
+			tenants, err := e.store.ListTenants(e.ctx)
+			if err != nil {
+				return err
+			}
+			tenantIDs = tenantIDs[:0]
+			for _, t := range tenants {
+				tenantIDs = append(tenantIDs, t.ID)
+			}
+		}
+		tokens := []*storage.JoinToken{}
+		for _, id := range tenantIDs {
+			list, err := e.store.ListJoinTokens(e.ctx, id)
+			if err != nil {
+				return err
+			}
+			tokens = append(tokens, list...)
+		}
+		rows := make([][]string, 0, len(tokens))
+		for _, jt := range tokens {
+			rows = append(rows, []string{jt.ID, jt.TenantID, adminTime(jt.ExpiresAt), strconv.FormatBool(jt.OneTime), adminTime(jt.UsedAt), strconv.FormatBool(jt.Revoked)})
+		}
+		return e.print(tokens, []string{"id", "tenant", "expires_at", "one_time", "used_at", "revoked"}, rows)
+	}
+}
+
+func adminJoinTokenCreate(fs *flag.FlagSet) func(*adminEnv) error {
+	tenant := fs.String("tenant", "", "Tenant the joining agents belong to")
+	ttl := fs.Int("ttl-minutes", 60*24, "Minutes until the token expires")
+	oneTime := fs.Bool("one-time", false, "Token can register a single agent")
+	return func(e *adminEnv) error {
+		if err := required("tenant", *tenant); err != nil {
+			return err
+		}
+		if *ttl <= 0 {
+			return fmt.Errorf("--ttl-minutes must be positive: %w", errAdminUsage)
+		}
+		jt, raw, err := e.store.CreateJoinToken(e.ctx, strings.TrimSpace(*tenant), *ttl, *oneTime)
+		if err != nil {
+			return err
+		}
+		e.audit("join_token.create", "join_token", jt.ID, jt.TenantID, fmt.Sprintf("Join token created for tenant %s from the admin CLI", jt.TenantID))
+		out := map[string]interface{}{"id": jt.ID, "token": raw, "tenant_id": jt.TenantID, "expires_at": jt.ExpiresAt.UTC().Format(time.RFC3339), "one_time": jt.OneTime}
+		return e.print(out, []string{"id", "token", "tenant", "expires_at"}, [][]string{{jt.ID, raw, jt.TenantID, adminTime(jt.ExpiresAt)}})
+	}
+}
+
+func adminJoinTokenRevoke(fs *flag.FlagSet) func(*adminEnv) error {
+	id := fs.String("id", "", "Join token ID")
+	return func(e *adminEnv) error {
+		if err := required("id", *id); err != nil {
+			return err
+		}
+		if err := e.store.RevokeJoinToken(e.ctx, strings.TrimSpace(*id)); err != nil {
+			return err
+		}
+		e.audit("join_token.revoke", "join_token", *id, "", "Join token revoked from the admin CLI")
+		fmt.Fprintf(e.stderr, "Revoked join token %s\n", *id)
+		return nil
+	}
+}
+
+// API tokens are long-lived sessions: the raw token works as a Bearer token
+// anywhere a web session does, with the user's role and tenant scope.
+
+// apiTokenID is the short identifier shown for a session: a prefix of its
+// stored hash, never the token itself.
+func apiTokenID(hash string) string {
+	if len(hash) > 12 {
+		return hash[:12]
+	}
+	return hash
+}
+
+func adminAPITokenList(fs *flag.FlagSet) func(*adminEnv) error {
+	username := fs.String("username", "", "Only tokens for this user")
+	return func(e *adminEnv) error {
+		sessions, err := e.store.ListSessions(e.ctx)
+		if err != nil {
+			return err
+		}
+		type tokenInfo struct {
+			ID        string    `json:"id"`
+			Kind      string    `json:"kind"`
+			Name      string    `json:"name,omitempty"`
+			Username  string    `json:"username"`
+			CreatedAt time.Time `json:"created_at"`
+			ExpiresAt time.Time `json:"expires_at"`
+		}
+		tokens := []tokenInfo{}
+		rows := [][]string{}
+		now := time.Now()
+		for _, s := range sessions {
+			if *username != "" && s.Username != *username {
+				continue
+			}
+			if s.ExpiresAt.Before(now) {
+				continue
+			}
+			info := tokenInfo{ID: apiTokenID(s.Token), Kind: s.Kind, Name: s.Name, Username: s.Username, CreatedAt: s.CreatedAt, ExpiresAt: s.ExpiresAt}
+			tokens = append(tokens, info)
+			rows = append(rows, []string{info.ID, info.Kind, info.Name, info.Username, adminTime(info.CreatedAt), adminTime(info.ExpiresAt)})
+		}
+		return e.print(tokens, []string{"id", "kind", "name", "username", "created_at", "expires_at"}, rows)
+	}
+}
+
+func adminAPITokenCreate(fs *flag.FlagSet) func(*adminEnv) error {
+	username := fs.String("username", "", "User the token acts as")
+	name := fs.String("name", "", "Label shown in the API key list")
+	ttlDays := fs.Int("ttl-days", 90, "Days until the token expires")
+	return func(e *adminEnv) error {
+		u, err := lookupUser(e, *username)
+		if err != nil {
+			return err
+		}
+		if *ttlDays <= 0 {
+			return fmt.Errorf("--ttl-days must be positive: %w", errAdminUsage)
+		}
+		ses, err := e.store.CreateAPIToken(e.ctx, u.ID, *name, *ttlDays*24*60)
+		if err != nil {
+			return err
+		}
+		e.audit("api_token.create", "user", strconv.FormatInt(u.ID, 10), u.TenantID, fmt.Sprintf("API token for %s created from the admin CLI (expires %s)", u.Username, ses.ExpiresAt.UTC().Format(time.RFC3339)))
+		out := map[string]interface{}{"token": ses.Token, "username": u.Username, "expires_at": ses.ExpiresAt.UTC().Format(time.RFC3339)}
+		return e.print(out, []string{"token", "username", "expires_at"}, [][]string{{ses.Token, u.Username, adminTime(ses.ExpiresAt)}})
+	}
+}
+
+func adminAPITokenRevoke(fs *flag.FlagSet) func(*adminEnv) error {
+	id := fs.String("id", "", "Token ID from 'api-token list'")
+	return func(e *adminEnv) error {
+		if err := required("id", *id); err != nil {
+			return err
+		}
+		sessions, err := e.store.ListSessions(e.ctx)
+		if err != nil {
+			return err
+		}
+		var matches []*storage.Session
+		for _, s := range sessions {
+			if strings.HasPrefix(s.Token, strings.TrimSpace(*id)) {
+				matches = append(matches, s)
+			}
+		}
+		switch len(matches) {
+		case 0:
+			return fmt.Errorf("no token with id %q", *id)
+		case 1:
+		default:
+			return fmt.Errorf("id %q matches %d tokens; use a longer prefix", *id, len(matches))
+		}
+		if err := e.store.DeleteSessionByHash(e.ctx, matches[0].Token); err != nil {
+			return err
+		}
+		e.audit("api_token.revoke", "user", strconv.FormatInt(matches[0].UserID, 10), "", fmt.Sprintf("Token %s for %s revoked from the admin CLI", apiTokenID(matches[0].Token), matches[0].Username))
+		fmt.Fprintf(e.stderr, "Revoked token %s\n", apiTokenID(matches[0].Token))
+		return nil
+	}
+}
+
+func adminAgentList(fs *flag.FlagSet) func(*adminEnv) error {
+	tenant := fs.String("tenant", "", "Only agents in this tenant")
+	return func(e *adminEnv) error {
+		agents, err := e.store.ListAgents(e.ctx)
+		if err != nil {
+			return err
+		}
+		filtered := []*storage.Agent{}
+		rows := [][]string{}
+		for _, a := range agents {
+			if *tenant != "" && a.TenantID != *tenant {
+				continue
+			}
+			a.Token = ""
+			filtered = append(filtered, a)
+			rows = append(rows, []string{a.AgentID, a.Name, a.TenantID, a.Status, a.Version, a.Platform, adminTime(a.LastSeen), strconv.Itoa(a.DeviceCount)})
+		}
+		return e.print(filtered, []string{"agent_id", "name", "tenant", "status", "version", "platform", "last_seen", "devices"}, rows)
+	}
+}
+
+func adminAgentShow(fs *flag.FlagSet) func(*adminEnv) error {
+	id := fs.String("id", "", "Agent ID")
+	return func(e *adminEnv) error {
+		if err := required("id", *id); err != nil {
+			return err
+		}
+		a, err := e.store.GetAgent(e.ctx, strings.TrimSpace(*id))
+		if err != nil {
+			return err
+		}
+		a.Token = ""
+		if e.json {
+			return e.print(a, nil, nil)
+		}
+		rows := [][]string{
+			{"agent_id", a.AgentID},
+			{"name", a.Name},
+			{"hostname", a.Hostname},
+			{"ip", a.IP},
+			{"tenant", a.TenantID},
+			{"status", a.Status},
+			{"version", a.Version},
+			{"platform", a.Platform + "/" + a.Architecture},
+			{"os_version", a.OSVersion},
+			{"registered_at", adminTime(a.RegisteredAt)},
+			{"last_seen", adminTime(a.LastSeen)},
+			{"last_heartbeat", adminTime(a.LastHeartbeat)},
+			{"last_device_sync", adminTime(a.LastDeviceSync)},
+			{"last_metrics_sync", adminTime(a.LastMetricsSync)},
+			{"devices", strconv.Itoa(a.DeviceCount)},
+		}
+		return e.print(a, []string{"field", "value"}, rows)
+	}
+}
+
+// Relays are regional forwarders for agent uploads; the token printed on
+// create is passed to "printmaster-server relay --token".
+
+func adminRelayList(fs *flag.FlagSet) func(*adminEnv) error {
+	return func(e *adminEnv) error {
+		relays, err := e.store.ListRelays(e.ctx)
+		if err != nil {
+			return err
+		}
+		now := time.Now()
+		views := make([]relayView, 0, len(relays))
+		rows := make([][]string, 0, len(relays))
+		for _, rl := range relays {
+			v := relayView{Relay: rl, Health: relay.Health(rl, now)}
+			views = append(views, v)
+			lastSeen := time.Time{}
+			if rl.LastSeen != nil {
+				lastSeen = *rl.LastSeen
+			}
+			rows = append(rows, []string{strconv.FormatInt(rl.ID, 10), rl.Name, rl.Region, rl.URL, v.Health, adminTime(lastSeen), strconv.Itoa(rl.AgentCount), strconv.FormatInt(rl.QueueDepth, 10)})
+		}
+		return e.print(views, []string{"id", "name", "region", "url", "health", "last_seen", "agents", "queued"}, rows)
+	}
+}
+
+func adminRelayCreate(fs *flag.FlagSet) func(*adminEnv) error {
+	name := fs.String("name", "", "Relay name")
+	region := fs.String("region", "", "Region label shown to admins")
+	url := fs.String("url", "", "Base URL agents use to reach the relay")
+	return func(e *adminEnv) error {
+		if err := required("name", *name); err != nil {
+			return err
+		}
+		if err := required("url", *url); err != nil {
+			return err
+		}
+		rl := &storage.Relay{Name: *name, Region: *region, URL: *url, CreatedBy: "admin-cli"}
+		if err := rl.Validate(); err != nil {
+			return fmt.Errorf("%v: %w", err, errAdminUsage)
+		}
+		raw, err := e.store.CreateRelay(e.ctx, rl)
+		if err != nil {
+			return err
+		}
+		id := strconv.FormatInt(rl.ID, 10)
+		e.audit("relay.create", "relay", id, "", fmt.Sprintf("Relay %s (%s) registered from the admin CLI", rl.Name, rl.URL))
+		out := map[string]interface{}{"id": rl.ID, "name": rl.Name, "url": rl.URL, "token": raw}
+		return e.print(out, []string{"id", "name", "url", "token"}, [][]string{{id, rl.Name, rl.URL, raw}})
+	}
+}
+
+func adminRelayDelete(fs *flag.FlagSet) func(*adminEnv) error {
+	id := fs.Int64("id", 0, "Relay ID")
+	return func(e *adminEnv) error {
+		if *id <= 0 {
+			return fmt.Errorf("--id is required: %w", errAdminUsage)
+		}
+		if err := e.store.DeleteRelay(e.ctx, *id); err != nil {
+			return err
+		}
+		e.audit("relay.delete", "relay", strconv.FormatInt(*id, 10), "", "Relay deleted from the admin CLI")
+		fmt.Fprintf(e.stderr, "Deleted relay %d\n", *id)
+		return nil
+	}
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"printmaster/server/storage"
+)
+
+// runAdminForTest runs an admin command against dbPath and restores the
+// globals the command replaces.
+func runAdminForTest(t *testing.T, dbPath, stdin string, args ...string) (int, string, string) {
+	t.Helper()
+	prevStore, prevConfig, prevLogger := serverStore, serverConfig, serverLogger
+	t.Cleanup(func() { serverStore, serverConfig, serverLogger = prevStore, prevConfig, prevLogger })
+
+	var stdout, stderr bytes.Buffer
+	code := runAdminCommand(append(args, "--db", dbPath), strings.NewReader(stdin), &stdout, &stderr)
+	return code, stdout.String(), stderr.String()
+}
+
+func TestAdminCLIManagesUsersAndTokens(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "server.db")
+	if err := os.WriteFile(dbPath, nil, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	if code, _, stderr := runAdminForTest(t, dbPath, "", "tenant", "create", "--name", "Acme", "--id", "acme"); code != adminExitOK {
+		t.Fatalf("tenant create failed: %s", stderr)
+	}
+	if code, _, stderr := runAdminForTest(t, dbPath, "Initial-Pass1\n", "user", "create", "--username", "ops", "--role", "operator", "--tenant", "acme", "--password-stdin"); code != adminExitOK {
+		t.Fatalf("user create failed: %s", stderr)
+	}
+
+	// Reset without a password generates one and prints it
+	code, stdout, stderr := runAdminForTest(t, dbPath, "", "user", "reset-password", "--username", "ops", "--json")
+	if code != adminExitOK {
+		t.Fatalf("reset-password failed: %s", stderr)
+	}
+	var cred map[string]string
+	if err := json.Unmarshal([]byte(stdout), &cred); err != nil || cred["password"] == "" {
+		t.Fatalf("expected generated password, got %q", stdout)
+	}
+
+	code, stdout, stderr = runAdminForTest(t, dbPath, "", "api-token", "create", "--username", "ops", "--json")
+	if code != adminExitOK {
+		t.Fatalf("api-token create failed: %s", stderr)
+	}
+	var token map[string]string
+	if err := json.Unmarshal([]byte(stdout), &token); err != nil || token["token"] == "" {
+		t.Fatalf("expected token, got %q", stdout)
+	}
+
+	store, err := storage.NewSQLiteStore(dbPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+	ctx := context.Background()
+	user, err := store.AuthenticateUser(ctx, "ops", cred["password"])
+	if err != nil {
+		t.Fatalf("generated password does not authenticate: %v", err)
+	}
+	if user.Role != storage.RoleOperator || user.TenantID != "acme" {
+		t.Fatalf("unexpected user: %+v", user)
+	}
+	if ses, err := store.GetSessionByToken(ctx, token["token"]); err != nil || ses.UserID != user.ID || ses.Kind != storage.SessionKindAPI {
+		t.Fatalf("api token is not a valid session: %v", err)
+	}
+	entries, err := store.GetAuditLog(ctx, "admin-cli", user.CreatedAt.AddDate(0, 0, -1))
+	if err != nil || len(entries) != 4 {
+		t.Fatalf("expected 4 audit entries, got %d (%v)", len(entries), err)
+	}
+}
+
+func TestAdminCLIRelays(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "server.db")
+	if err := os.WriteFile(dbPath, nil, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	if code, _, _ := runAdminForTest(t, dbPath, "", "relay", "create", "--name", "eu", "--url", "relay-eu"); code != adminExitUsage {
+		t.Fatalf("relay without an absolute URL: exit %d", code)
+	}
+	code, stdout, stderr := runAdminForTest(t, dbPath, "", "relay", "create", "--name", "eu", "--region", "Europe", "--url", "https://relay-eu.example.com", "--json")
+	if code != adminExitOK {
+		t.Fatalf("relay create failed: %s", stderr)
+	}
+	var created struct {
+		ID    int64  `json:"id"`
+		Token string `json:"token"`
+	}
+	if err := json.Unmarshal([]byte(stdout), &created); err != nil || created.Token == "" {
+		t.Fatalf("expected relay token, got %q", stdout)
+	}
+	code, stdout, _ = runAdminForTest(t, dbPath, "", "relay", "list")
+	if code != adminExitOK || !strings.Contains(stdout, "relay-eu.example.com") || !strings.Contains(stdout, "offline") {
+		t.Fatalf("relay list: exit %d\n%s", code, stdout)
+	}
+	if code, _, stderr := runAdminForTest(t, dbPath, "", "relay", "delete", "--id", strconv.FormatInt(created.ID, 10)); code != adminExitOK {
+		t.Fatalf("relay delete failed: %s", stderr)
+	}
+}
+
+func TestAdminCLIUsageErrors(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "server.db")
+	if err := os.WriteFile(dbPath, nil, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	cases := [][]string{
+		{"user"},
+		{"widget", "list"},
+		{"user", "frobnicate"},
+		{"user", "create"},
+		{"user", "create", "--username", "x", "--role", "superuser"},
+		{"join-token", "create"},
+	}
+	for _, args := range cases {
+		if code, _, stderr := runAdminForTest(t, dbPath, "", args...); code != adminExitUsage {
+			t.Errorf("%v: exit code %d, want %d (stderr: %s)", args, code, adminExitUsage, stderr)
+		}
+	}
+	if code, _, _ := runAdminForTest(t, filepath.Join(t.TempDir(), "missing.db"), "", "user", "list"); code != adminExitError {
+		t.Errorf("missing database: exit code %d, want %d", code, adminExitError)
+	}
+}
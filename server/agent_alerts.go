@@ -0,0 +1,125 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"printmaster/server/storage"
+)
+
+// agentAlertTypes lists the alert types agents may raise from their local
+// alert engine.
+var agentAlertTypes = map[string]bool{
+	storage.AlertTypeTonerLow:      true,
+	storage.AlertTypePaperJam:      true,
+	storage.AlertTypeDeviceOffline: true,
+}
+
+// agentAlertReport is one alert change forwarded by an agent.
+type agentAlertReport struct {
+	State        string    `json:"state"` // "raised" or "resolved"
+	Type         string    `json:"type"`
+	Severity     string    `json:"severity"`
+	DeviceSerial string    `json:"device_serial"`
+	DeviceIP     string    `json:"device_ip"`
+	Title        string    `json:"title"`
+	Message      string    `json:"message"`
+	At           time.Time `json:"at"`
+}
+
+// handleAgentAlerts records alerts raised and resolved by the authenticated
+// agent's local alert engine. A raised alert is skipped when the same device
+// already has an active alert of that type from this agent.
+// POST /api/v1/agents/alerts
+func handleAgentAlerts(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "POST only", http.StatusMethodNotAllowed)
+		return
+	}
+	agent, ok := r.Context().Value(agentContextKey).(*storage.Agent)
+	if !ok || agent == nil {
+		http.Error(w, "unauthenticated", http.StatusUnauthorized)
+		return
+	}
+	var req struct {
+		AgentID string             `json:"agent_id"`
+		Alerts  []agentAlertReport `json:"alerts"`
+	}
+	if err := decodeJSONBody(r, &req); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+	for _, report := range req.Alerts {
+		if !agentAlertTypes[report.Type] || strings.TrimSpace(report.DeviceSerial) == "" {
+			http.Error(w, "unsupported alert", http.StatusBadRequest)
+			return
+		}
+		if report.State != "raised" && report.State != "resolved" {
+			http.Error(w, "state must be raised or resolved", http.StatusBadRequest)
+			return
+		}
+	}
+
+	ctx := r.Context()
+	created, resolved := 0, 0
+	for _, report := range req.Alerts {
+		active, err := serverStore.ListActiveAlerts(ctx, storage.AlertFilters{Type: report.Type, TenantID: agent.TenantID})
+		if err != nil {
+			http.Error(w, "failed to list alerts", http.StatusInternalServerError)
+			return
+		}
+		var matches []storage.Alert
+		for _, a := range active {
+			if a.AgentID == agent.AgentID && a.DeviceSerial == report.DeviceSerial {
+				matches = append(matches, a)
+			}
+		}
+
+		if report.State == "resolved" {
+			for _, a := range matches {
+				if err := serverStore.ResolveAlert(ctx, a.ID); err != nil {
+					logWarn("Failed to resolve agent alert", "alert_id", a.ID, "error", err)
+					continue
+				}
+				resolved++
+			}
+			continue
+		}
+		if len(matches) > 0 {
+			continue
+		}
+		severity := report.Severity
+		switch severity {
+		case storage.AlertSeverityInfo, storage.AlertSeverityWarning, storage.AlertSeverityCritical:
+		default:
+			severity = storage.AlertSeverityWarning
+		}
+		triggered := report.At
+		if triggered.IsZero() {
+			triggered = time.Now()
+		}
+		details, _ := json.Marshal(map[string]string{"source": "agent", "device_ip": report.DeviceIP})
+		if _, err := serverStore.CreateAlert(ctx, &storage.Alert{
+			Type:         report.Type,
+			Severity:     severity,
+			Scope:        storage.AlertScopeDevice,
+			Status:       storage.AlertStatusActive,
+			TenantID:     agent.TenantID,
+			AgentID:      agent.AgentID,
+			DeviceSerial: report.DeviceSerial,
+			Title:        report.Title,
+			Message:      report.Message,
+			Details:      string(details),
+			TriggeredAt:  triggered,
+		}); err != nil {
+			http.Error(w, "failed to create alert", http.StatusInternalServerError)
+			return
+		}
+		created++
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"success": true, "created": created, "resolved": resolved})
+}
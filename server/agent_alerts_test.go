@@ -0,0 +1,50 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"printmaster/server/storage"
+)
+
+func TestHandleAgentAlerts(t *testing.T) {
+	store := SetupTestStore(t)
+	agent := &storage.Agent{AgentID: "agent-alerts", Hostname: "host", TenantID: "t1"}
+	post := func(body string) int {
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/agents/alerts", bytes.NewBufferString(body))
+		req = req.WithContext(context.WithValue(req.Context(), agentContextKey, agent))
+		rr := httptest.NewRecorder()
+		handleAgentAlerts(rr, req)
+		return rr.Code
+	}
+
+	if code := post(`{"alerts":[{"state":"raised","type":"agent_offline","device_serial":"SN1"}]}`); code != http.StatusBadRequest {
+		t.Fatalf("agents must not raise arbitrary alert types, got %d", code)
+	}
+	raised := `{"alerts":[{"state":"raised","type":"toner_low","severity":"warning","device_serial":"SN1","title":"Toner low"}]}`
+	for i := 0; i < 2; i++ {
+		if code := post(raised); code != http.StatusOK {
+			t.Fatalf("unexpected status %d", code)
+		}
+	}
+
+	ctx := context.Background()
+	active, err := store.ListActiveAlerts(ctx, storage.AlertFilters{Type: storage.AlertTypeTonerLow})
+	if err != nil || len(active) != 1 {
+		t.Fatalf("expected one active alert, got %d (%v)", len(active), err)
+	}
+	if a := active[0]; a.AgentID != "agent-alerts" || a.TenantID != "t1" || a.DeviceSerial != "SN1" || a.Scope != storage.AlertScopeDevice {
+		t.Fatalf("unexpected alert: %+v", a)
+	}
+
+	if code := post(`{"alerts":[{"state":"resolved","type":"toner_low","device_serial":"SN1"}]}`); code != http.StatusOK {
+		t.Fatalf("unexpected status %d", code)
+	}
+	active, _ = store.ListActiveAlerts(ctx, storage.AlertFilters{Type: storage.AlertTypeTonerLow})
+	if len(active) != 0 {
+		t.Fatalf("alert not resolved: %+v", active)
+	}
+}
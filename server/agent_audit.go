@@ -0,0 +1,65 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"printmaster/server/storage"
+)
+
+// agentAuditActions lists the events agents may record in the audit log and
+// the severity each is stored with. Anything else is rejected so a
+// compromised agent token cannot write arbitrary audit entries.
+var agentAuditActions = map[string]storage.AuditSeverity{
+	"snmp.community_sweep.matched":   storage.AuditSeverityWarn,
+	"snmp.community_sweep.exhausted": storage.AuditSeverityInfo,
+}
+
+// handleAgentAuditLog records an audit event reported by the authenticated agent.
+// POST /api/v1/audit/log
+func handleAgentAuditLog(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "POST only", http.StatusMethodNotAllowed)
+		return
+	}
+	agent, ok := r.Context().Value(agentContextKey).(*storage.Agent)
+	if !ok || agent == nil {
+		http.Error(w, "unauthenticated", http.StatusUnauthorized)
+		return
+	}
+	var req struct {
+		Action       string                 `json:"action"`
+		ResourceType string                 `json:"resource_type"`
+		ResourceID   string                 `json:"resource_id"`
+		Details      map[string]interface{} `json:"details"`
+	}
+	if err := decodeJSONBody(r, &req); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+	action := strings.TrimSpace(req.Action)
+	severity, known := agentAuditActions[action]
+	if !known {
+		http.Error(w, "unsupported audit action", http.StatusBadRequest)
+		return
+	}
+
+	logAuditEntry(r.Context(), &storage.AuditEntry{
+		ActorType:  storage.AuditActorAgent,
+		ActorID:    agent.AgentID,
+		ActorName:  agent.Hostname,
+		TenantID:   agent.TenantID,
+		Action:     action,
+		TargetType: req.ResourceType,
+		TargetID:   req.ResourceID,
+		Severity:   severity,
+		Details:    fmt.Sprintf("Agent %s reported %s for %s %s", agent.AgentID, action, req.ResourceType, req.ResourceID),
+		Metadata:   req.Details,
+		IPAddress:  extractClientIP(r),
+		UserAgent:  r.UserAgent(),
+	})
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"success": true})
+}
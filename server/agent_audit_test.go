@@ -0,0 +1,40 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"printmaster/server/storage"
+)
+
+func TestHandleAgentAuditLog(t *testing.T) {
+	store := SetupTestStore(t)
+	agent := &storage.Agent{AgentID: "agent-audit", Hostname: "host", TenantID: "t1"}
+	post := func(body string) int {
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/audit/log", bytes.NewBufferString(body))
+		req = req.WithContext(context.WithValue(req.Context(), agentContextKey, agent))
+		rr := httptest.NewRecorder()
+		handleAgentAuditLog(rr, req)
+		return rr.Code
+	}
+
+	if code := post(`{"action":"auth.login","resource_type":"user","resource_id":"admin"}`); code != http.StatusBadRequest {
+		t.Fatalf("agents must not record arbitrary actions, got %d", code)
+	}
+	if code := post(`{"action":"snmp.community_sweep.matched","resource_type":"device","resource_id":"10.0.0.5","details":{"candidate":2,"attempts":1}}`); code != http.StatusOK {
+		t.Fatalf("unexpected status %d", code)
+	}
+
+	entries, err := store.GetAuditLog(context.Background(), "agent-audit", time.Time{})
+	if err != nil || len(entries) != 1 {
+		t.Fatalf("GetAuditLog: %d entries, %v", len(entries), err)
+	}
+	entry := entries[0]
+	if entry.ActorType != storage.AuditActorAgent || entry.TenantID != "t1" || entry.Severity != storage.AuditSeverityWarn || entry.TargetID != "10.0.0.5" {
+		t.Fatalf("unexpected audit entry: %+v", entry)
+	}
+}
@@ -0,0 +1,34 @@
+package main
+
+import "hash/fnv"
+
+// Intervals assumed when an agent does not report its own (the agent defaults).
+const (
+	defaultAgentUploadIntervalSeconds    = 300
+	defaultAgentHeartbeatIntervalSeconds = 60
+)
+
+// agentScheduleHint suggests where within its upload and heartbeat intervals an
+// agent should do its periodic work. Offsets are derived from the agent ID, so
+// they stay stable across heartbeats and restarts while spreading the fleet
+// evenly instead of letting agents with identical intervals arrive together.
+func agentScheduleHint(agentID string, uploadIntervalSeconds, heartbeatIntervalSeconds int) map[string]int {
+	if uploadIntervalSeconds <= 0 {
+		uploadIntervalSeconds = defaultAgentUploadIntervalSeconds
+	}
+	if heartbeatIntervalSeconds <= 0 {
+		heartbeatIntervalSeconds = defaultAgentHeartbeatIntervalSeconds
+	}
+	return map[string]int{
+		"upload_offset_seconds":    scheduleOffset(agentID, "upload", uploadIntervalSeconds),
+		"heartbeat_offset_seconds": scheduleOffset(agentID, "heartbeat", heartbeatIntervalSeconds),
+	}
+}
+
+func scheduleOffset(agentID, loop string, intervalSeconds int) int {
+	h := fnv.New64a()
+	h.Write([]byte(loop))
+	h.Write([]byte{0})
+	h.Write([]byte(agentID))
+	return int(h.Sum64() % uint64(intervalSeconds))
+}
@@ -0,0 +1,36 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestAgentScheduleHintIsStableAndSpread(t *testing.T) {
+	t.Parallel()
+	a := agentScheduleHint("agent-1", 0, 0)
+	if b := agentScheduleHint("agent-1", 0, 0); a["upload_offset_seconds"] != b["upload_offset_seconds"] || a["heartbeat_offset_seconds"] != b["heartbeat_offset_seconds"] {
+		t.Fatalf("hint not stable: %v vs %v", a, b)
+	}
+
+	// 600 agents over a 300s upload interval should land in most 30s buckets
+	// and never pile up on a single second.
+	buckets := make(map[int]int)
+	perSecond := make(map[int]int)
+	for i := 0; i < 600; i++ {
+		hint := agentScheduleHint(fmt.Sprintf("agent-%d", i), 300, 30)
+		up := hint["upload_offset_seconds"]
+		if up < 0 || up >= 300 || hint["heartbeat_offset_seconds"] >= 30 {
+			t.Fatalf("offset out of range: %v", hint)
+		}
+		buckets[up/30]++
+		perSecond[up]++
+	}
+	if len(buckets) != 10 {
+		t.Errorf("agents only spread over %d of 10 buckets", len(buckets))
+	}
+	for sec, n := range perSecond {
+		if n > 12 {
+			t.Errorf("%d agents share upload offset %ds", n, sec)
+		}
+	}
+}
@@ -0,0 +1,243 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	authz "printmaster/server/authz"
+	"printmaster/server/storage"
+)
+
+const (
+	// defaultAgentTokenLifetimeDays applies when security.agent_token_lifetime_days is unset.
+	defaultAgentTokenLifetimeDays = 90
+	// agentTokenRotationGrace keeps a replaced token valid long enough for the
+	// agent to persist its successor and finish requests already in flight.
+	agentTokenRotationGrace = 10 * time.Minute
+)
+
+// agentTokenLifetime returns how long newly issued agent tokens stay valid.
+func agentTokenLifetime() time.Duration {
+	days := defaultAgentTokenLifetimeDays
+	if serverConfig != nil && serverConfig.Security.AgentTokenLifetimeDays > 0 {
+		days = serverConfig.Security.AgentTokenLifetimeDays
+	}
+	return time.Duration(days) * 24 * time.Hour
+}
+
+// agentTokenRenewal is a token handed to an agent in a heartbeat response.
+type agentTokenRenewal struct {
+	Token     string
+	ExpiresAt time.Time
+}
+
+// fields returns the heartbeat response keys carrying the renewal.
+func (r *agentTokenRenewal) fields() map[string]interface{} {
+	return map[string]interface{}{
+		"agent_token":            r.Token,
+		"agent_token_expires_at": r.ExpiresAt.UTC().Format(time.RFC3339),
+	}
+}
+
+// agentTokenRotationReason reports why the agent's token should be replaced,
+// or "" when it is still good. Tokens without an expiry were issued before
+// the agent negotiated rotation and are replaced with one that expires.
+func agentTokenRotationReason(agent *storage.Agent, now time.Time) string {
+	switch {
+	case agent.TokenRotationRequested:
+		return "forced"
+	case agent.TokenExpiresAt.IsZero():
+		return "initial"
+	case agent.TokenExpiresAt.Sub(now) < agentTokenLifetime()/4:
+		return "expiring"
+	}
+	return ""
+}
+
+// hashRotationNonce returns the stored form of an agent's rotation nonce.
+func hashRotationNonce(nonce string) string {
+	if nonce == "" {
+		return ""
+	}
+	sum := sha256.Sum256([]byte(nonce))
+	return hex.EncodeToString(sum[:])
+}
+
+// renewAgentToken runs the rotation negotiated during a heartbeat. agent must
+// be freshly loaded, presented is the token the agent authenticated with and
+// nonce the per-process nonce it sent alongside. Agents that do not advertise
+// rotation support keep their token.
+//
+// An agent still presenting the token replaced by the last rotation has not
+// persisted its successor, so it is sent again rather than rotating a second
+// time, but only when it sends the nonce of the heartbeat that rotated: the
+// bearer token alone must not be enough to obtain its successor. Forced
+// rotations answer a suspected leak, so they revoke the previous token at once
+// and never resend.
+func renewAgentToken(ctx context.Context, store storage.Store, agent *storage.Agent, presented, nonce string, supported bool, clientIP string) (*agentTokenRenewal, error) {
+	if agent == nil || !supported {
+		return nil, nil
+	}
+	if presented != "" && presented != agent.Token {
+		stored, err := store.GetAgentTokenResendNonce(ctx, agent.AgentID)
+		if err != nil {
+			return nil, err
+		}
+		if stored == "" || nonce == "" || subtle.ConstantTimeCompare([]byte(stored), []byte(hashRotationNonce(nonce))) != 1 {
+			logWarn("Not resending rotated agent token: rotation nonce missing or mismatched", "agent_id", agent.AgentID, "ip", clientIP)
+			return nil, nil
+		}
+		logInfo("Resending rotated agent token", "agent_id", agent.AgentID)
+		return &agentTokenRenewal{Token: agent.Token, ExpiresAt: agent.TokenExpiresAt}, nil
+	}
+
+	now := time.Now().UTC()
+	reason := agentTokenRotationReason(agent, now)
+	if reason == "" {
+		return nil, nil
+	}
+	token, err := generateToken()
+	if err != nil {
+		return nil, err
+	}
+	expiresAt := now.Add(agentTokenLifetime())
+	graceUntil, resendNonce := now.Add(agentTokenRotationGrace), hashRotationNonce(nonce)
+	if reason == "forced" {
+		graceUntil, resendNonce = time.Time{}, ""
+	}
+	if err := store.RotateAgentToken(ctx, agent.AgentID, token, resendNonce, expiresAt, graceUntil); err != nil {
+		return nil, err
+	}
+
+	logInfo("Rotated agent token", "agent_id", agent.AgentID, "reason", reason, "expires_at", expiresAt.Format(time.RFC3339))
+	logAuditEntry(ctx, &storage.AuditEntry{
+		ActorType:  storage.AuditActorSystem,
+		ActorID:    "server",
+		TenantID:   agent.TenantID,
+		Action:     "agent.token_rotated",
+		TargetType: "agent",
+		TargetID:   agent.AgentID,
+		Details:    fmt.Sprintf("Issued a new token to agent %s (%s)", agent.AgentID, reason),
+		Metadata: map[string]interface{}{
+			"reason":       reason,
+			"expires_at":   expiresAt.Format(time.RFC3339),
+			"token_prefix": maskSensitiveToken(presented),
+		},
+		IPAddress: clientIP,
+	})
+	return &agentTokenRenewal{Token: token, ExpiresAt: expiresAt}, nil
+}
+
+// handleAgentTokenRotate forces token rotation after a suspected leak.
+// POST /api/v1/agents/tokens/rotate with {"agent_ids": [...]} or {"all": true}.
+// Flagged agents receive a new token on their next heartbeat; the old one
+// stops working immediately and is never answered with the new one.
+func handleAgentTokenRotate(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "POST only", http.StatusMethodNotAllowed)
+		return
+	}
+	var req struct {
+		AgentIDs []string `json:"agent_ids"`
+		All      bool     `json:"all"`
+	}
+	if err := decodeJSONBody(r, &req); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	principal := getPrincipal(r)
+	if principal == nil {
+		http.Error(w, "unauthenticated", http.StatusUnauthorized)
+		return
+	}
+	scope, ok := tenantScope(principal)
+	if !ok {
+		http.Error(w, "forbidden", http.StatusForbidden)
+		return
+	}
+	ctx := r.Context()
+
+	if req.All {
+		if !authorizeOrReject(w, r, authz.ActionAgentTokensRotate, authz.ResourceRef{}) {
+			return
+		}
+		var tenantIDs []string
+		for id := range scope {
+			tenantIDs = append(tenantIDs, id)
+		}
+		if scope != nil && len(tenantIDs) == 0 {
+			http.Error(w, "forbidden", http.StatusForbidden)
+			return
+		}
+		count, err := serverStore.RequestFleetTokenRotation(ctx, tenantIDs)
+		if err != nil {
+			logError("Failed to request fleet token rotation", "error", err)
+			http.Error(w, "Failed to request token rotation", http.StatusInternalServerError)
+			return
+		}
+		logRequestAudit(r, &storage.AuditEntry{
+			Action:     "agent.token_rotation_forced",
+			TargetType: "agent",
+			TargetID:   "*",
+			Details:    fmt.Sprintf("Forced token rotation for %d agents", count),
+			Severity:   storage.AuditSeverityWarn,
+			Metadata: map[string]interface{}{
+				"scope":      "fleet",
+				"tenant_ids": tenantIDs,
+				"count":      count,
+			},
+		})
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{"success": true, "requested": count})
+		return
+	}
+
+	if len(req.AgentIDs) == 0 {
+		http.Error(w, "agent_ids or all required", http.StatusBadRequest)
+		return
+	}
+	var requested []string
+	for _, agentID := range req.AgentIDs {
+		agentID = strings.TrimSpace(agentID)
+		if agentID == "" {
+			continue
+		}
+		agent, err := serverStore.GetAgent(ctx, agentID)
+		if err != nil || agent == nil {
+			http.Error(w, "Agent not found: "+agentID, http.StatusNotFound)
+			return
+		}
+		if !tenantAllowed(scope, agent.TenantID) {
+			http.Error(w, "forbidden", http.StatusForbidden)
+			return
+		}
+		if !authorizeOrReject(w, r, authz.ActionAgentTokensRotate, authz.ResourceRef{TenantIDs: []string{agent.TenantID}}) {
+			return
+		}
+		if err := serverStore.RequestAgentTokenRotation(ctx, agentID); err != nil {
+			logError("Failed to request agent token rotation", "agent_id", agentID, "error", err)
+			http.Error(w, "Failed to request token rotation", http.StatusInternalServerError)
+			return
+		}
+		requested = append(requested, agentID)
+		logRequestAudit(r, &storage.AuditEntry{
+			Action:     "agent.token_rotation_forced",
+			TargetType: "agent",
+			TargetID:   agentID,
+			TenantID:   agent.TenantID,
+			Details:    fmt.Sprintf("Forced token rotation for agent %s", agentID),
+			Severity:   storage.AuditSeverityWarn,
+			Metadata:   map[string]interface{}{"scope": "agent"},
+		})
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"success": true, "requested": len(requested)})
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"printmaster/server/storage"
+)
+
+func TestRenewAgentTokenNegotiation(t *testing.T) {
+	store := SetupTestStore(t)
+	ctx := context.Background()
+	agent := &storage.Agent{
+		AgentID: "agent-renew", Hostname: "host", IP: "10.0.0.1", Platform: "linux",
+		Version: "1.0.0", ProtocolVersion: "1", Token: "token-0", Status: "active",
+		RegisteredAt: time.Now(), LastSeen: time.Now(),
+	}
+	if err := store.RegisterAgent(ctx, agent); err != nil {
+		t.Fatalf("RegisterAgent: %v", err)
+	}
+	load := func() *storage.Agent {
+		a, err := store.GetAgent(ctx, "agent-renew")
+		if err != nil {
+			t.Fatalf("GetAgent: %v", err)
+		}
+		return a
+	}
+
+	const nonce = "agent-process-nonce"
+	if renewal, err := renewAgentToken(ctx, store, load(), "token-0", nonce, false, ""); err != nil || renewal != nil {
+		t.Fatalf("agents without rotation support keep their token: %+v %v", renewal, err)
+	}
+
+	// First heartbeat from a capable agent replaces the non-expiring token
+	first, err := renewAgentToken(ctx, store, load(), "token-0", nonce, true, "")
+	if err != nil || first == nil || first.Token == "token-0" {
+		t.Fatalf("expected initial rotation: %+v %v", first, err)
+	}
+	if remaining := time.Until(first.ExpiresAt); remaining < agentTokenLifetime()-time.Minute {
+		t.Fatalf("unexpected expiry: %v", first.ExpiresAt)
+	}
+
+	// Agent that did not persist the new token is sent the same one again,
+	// but the replaced token alone is not enough to obtain it
+	for _, other := range []string{"", "someone-else"} {
+		if renewal, err := renewAgentToken(ctx, store, load(), "token-0", other, true, ""); err != nil || renewal != nil {
+			t.Fatalf("resend with nonce %q: %+v %v", other, renewal, err)
+		}
+	}
+	resend, err := renewAgentToken(ctx, store, load(), "token-0", nonce, true, "")
+	if err != nil || resend == nil || resend.Token != first.Token {
+		t.Fatalf("expected resend of current token: %+v %v", resend, err)
+	}
+
+	if renewal, err := renewAgentToken(ctx, store, load(), first.Token, nonce, true, ""); err != nil || renewal != nil {
+		t.Fatalf("fresh token should not rotate: %+v %v", renewal, err)
+	}
+}
+
+func TestRenewAgentTokenForcedRevokesPrevious(t *testing.T) {
+	store := SetupTestStore(t)
+	ctx := context.Background()
+	expires := time.Now().Add(agentTokenLifetime())
+	if err := store.RegisterAgent(ctx, &storage.Agent{
+		AgentID: "agent-leak", Hostname: "host", IP: "10.0.0.1", Platform: "linux",
+		Version: "1.0.0", ProtocolVersion: "1", Token: "token-leaked", Status: "active",
+		TokenExpiresAt: expires, RegisteredAt: time.Now(), LastSeen: time.Now(),
+	}); err != nil {
+		t.Fatalf("RegisterAgent: %v", err)
+	}
+	if err := store.RequestAgentTokenRotation(ctx, "agent-leak"); err != nil {
+		t.Fatalf("RequestAgentTokenRotation: %v", err)
+	}
+	agent, err := store.GetAgent(ctx, "agent-leak")
+	if err != nil {
+		t.Fatalf("GetAgent: %v", err)
+	}
+
+	const nonce = "agent-process-nonce"
+	forced, err := renewAgentToken(ctx, store, agent, "token-leaked", nonce, true, "")
+	if err != nil || forced == nil || forced.Token == "token-leaked" {
+		t.Fatalf("expected forced rotation: %+v %v", forced, err)
+	}
+	if _, err := store.GetAgentByToken(ctx, "token-leaked"); err == nil {
+		t.Fatalf("leaked token should stop working at once after a forced rotation")
+	}
+
+	// Even a heartbeat that repeats the nonce is not sent the new token
+	agent, _ = store.GetAgent(ctx, "agent-leak")
+	if renewal, err := renewAgentToken(ctx, store, agent, "token-leaked", nonce, true, ""); err != nil || renewal != nil {
+		t.Fatalf("forced rotation must not be resent: %+v %v", renewal, err)
+	}
+}
+
+func TestHandleAgentTokenRotateFleet(t *testing.T) {
+	store := SetupTestStore(t)
+	ctx := context.Background()
+	for _, id := range []string{"a1", "a2"} {
+		if err := store.RegisterAgent(ctx, &storage.Agent{
+			AgentID: id, Hostname: id, IP: "10.0.0.1", Platform: "linux", Version: "1",
+			ProtocolVersion: "1", Token: "tok-" + id, Status: "active", TenantID: "t1",
+			RegisteredAt: time.Now(), LastSeen: time.Now(),
+		}); err != nil {
+			t.Fatalf("RegisterAgent: %v", err)
+		}
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/agents/tokens/rotate", bytes.NewBufferString(`{"all":true}`))
+	rr := httptest.NewRecorder()
+	handleAgentTokenRotate(rr, InjectTestAdmin(req))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("unexpected status %d: %s", rr.Code, rr.Body.String())
+	}
+	var resp map[string]interface{}
+	_ = json.Unmarshal(rr.Body.Bytes(), &resp)
+	if resp["requested"] != float64(2) {
+		t.Fatalf("unexpected response: %v", resp)
+	}
+
+	req = httptest.NewRequest(http.MethodPost, "/api/v1/agents/tokens/rotate", bytes.NewBufferString(`{"agent_ids":["a1"]}`))
+	rr = httptest.NewRecorder()
+	handleAgentTokenRotate(rr, InjectTestUser(req, NewTestUser(storage.RoleOperator, "t1")))
+	if rr.Code != http.StatusForbidden {
+		t.Fatalf("operators should not force rotation, got %d", rr.Code)
+	}
+}
@@ -0,0 +1,301 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"printmaster/common/updatepolicy"
+	authz "printmaster/server/authz"
+	"printmaster/server/storage"
+)
+
+// maxClusterAgentIDs caps how many agent IDs a failure cluster lists.
+const maxClusterAgentIDs = 50
+
+// Patterns stripped from update error messages so the same failure on
+// different agents produces the same signature.
+var (
+	updateErrGUID    = regexp.MustCompile(`(?i)\{?[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}\}?`)
+	updateErrHex     = regexp.MustCompile(`(?i)\b[0-9a-f]{16,}\b`)
+	updateErrWinPath = regexp.MustCompile(`(?i)[a-z]:\\(?:[^\\"':\r\n]*\\)*[^\\\s"':]*`) // Directories may contain spaces
+	updateErrUnixDir = regexp.MustCompile(`(?:/[^\s"':/]+){2,}/?`)
+	updateErrAddr    = regexp.MustCompile(`\b\d{1,3}(?:\.\d{1,3}){3}(?::\d+)?\b`)
+	updateErrNumber  = regexp.MustCompile(`\d+`)
+	updateErrSpace   = regexp.MustCompile(`\s+`)
+)
+
+// updateErrorSignature normalizes an update failure into a signature shared by
+// every agent that hit the same problem: IDs, hashes, paths, addresses and
+// numbers are replaced by placeholders.
+func updateErrorSignature(code, message string) string {
+	msg := strings.ToLower(message)
+	msg = updateErrGUID.ReplaceAllString(msg, "<id>")
+	msg = updateErrHex.ReplaceAllString(msg, "<hash>")
+	msg = updateErrWinPath.ReplaceAllString(msg, "<path>")
+	msg = updateErrUnixDir.ReplaceAllString(msg, "<path>")
+	msg = updateErrAddr.ReplaceAllString(msg, "<addr>")
+	msg = updateErrNumber.ReplaceAllString(msg, "<n>")
+	msg = strings.TrimSpace(updateErrSpace.ReplaceAllString(msg, " "))
+	if len(msg) > 200 {
+		msg = msg[:200]
+	}
+	if code == "" {
+		code = "UNKNOWN"
+	}
+	if msg == "" {
+		return code
+	}
+	return code + ": " + msg
+}
+
+// updateFailureCluster groups failed update runs that share a target version
+// and error signature.
+type updateFailureCluster struct {
+	Signature     string         `json:"signature"`
+	ErrorCode     string         `json:"error_code"`
+	TargetVersion string         `json:"target_version"`
+	SampleMessage string         `json:"sample_message"`
+	SampleRunID   int64          `json:"sample_run_id"` // Most recent run, for opening its diagnostics
+	AgentCount    int            `json:"agent_count"`
+	RunCount      int            `json:"run_count"`
+	AgentIDs      []string       `json:"agent_ids"`
+	Platforms     map[string]int `json:"platforms,omitempty"` // "os/arch" from diagnostics
+	FirstSeen     time.Time      `json:"first_seen"`
+	LastSeen      time.Time      `json:"last_seen"`
+}
+
+// clusterUpdateFailures groups failed runs by target version and error
+// signature, largest clusters (by distinct agents) first. runs are expected
+// newest first, as returned by ListAgentUpdateRuns.
+func clusterUpdateFailures(runs []*storage.AgentUpdateRun) []*updateFailureCluster {
+	byKey := make(map[string]*updateFailureCluster)
+	agents := make(map[string]map[string]struct{})
+	var clusters []*updateFailureCluster
+	for _, run := range runs {
+		sig := run.ErrorSignature
+		if sig == "" {
+			sig = updateErrorSignature(run.ErrorCode, run.ErrorMessage)
+		}
+		key := run.TargetVersion + "\x00" + sig
+		c := byKey[key]
+		if c == nil {
+			c = &updateFailureCluster{
+				Signature:     sig,
+				ErrorCode:     run.ErrorCode,
+				TargetVersion: run.TargetVersion,
+				SampleMessage: run.ErrorMessage,
+				SampleRunID:   run.ID,
+				AgentIDs:      []string{},
+				FirstSeen:     run.UpdatedAt,
+				LastSeen:      run.UpdatedAt,
+			}
+			byKey[key] = c
+			agents[key] = make(map[string]struct{})
+			clusters = append(clusters, c)
+		}
+		c.RunCount++
+		if run.UpdatedAt.Before(c.FirstSeen) {
+			c.FirstSeen = run.UpdatedAt
+		}
+		if run.UpdatedAt.After(c.LastSeen) {
+			c.LastSeen = run.UpdatedAt
+			c.SampleMessage = run.ErrorMessage
+			c.SampleRunID = run.ID
+		}
+		if _, seen := agents[key][run.AgentID]; !seen {
+			agents[key][run.AgentID] = struct{}{}
+			c.AgentCount++
+			if len(c.AgentIDs) < maxClusterAgentIDs {
+				c.AgentIDs = append(c.AgentIDs, run.AgentID)
+			}
+		}
+		if d := run.Diagnostics; d != nil && d.OS != "" {
+			if c.Platforms == nil {
+				c.Platforms = make(map[string]int)
+			}
+			c.Platforms[d.OS+"/"+d.Arch]++
+		}
+	}
+	sort.SliceStable(clusters, func(i, j int) bool {
+		if clusters[i].AgentCount != clusters[j].AgentCount {
+			return clusters[i].AgentCount > clusters[j].AgentCount
+		}
+		return clusters[i].LastSeen.After(clusters[j].LastSeen)
+	})
+	return clusters
+}
+
+// trimUpdateDiagnostics enforces the agent-side collection limits so a
+// misbehaving agent cannot store arbitrarily large reports.
+func trimUpdateDiagnostics(d *updatepolicy.FailureDiagnostics) {
+	if d == nil {
+		return
+	}
+	if n := len(d.LogTail); n > updatepolicy.MaxDiagnosticLogLines {
+		d.LogTail = d.LogTail[n-updatepolicy.MaxDiagnosticLogLines:]
+	}
+	for i, line := range d.LogTail {
+		if len(line) > updatepolicy.MaxDiagnosticLogLineLength {
+			d.LogTail[i] = line[:updatepolicy.MaxDiagnosticLogLineLength]
+		}
+	}
+	if n := len(d.InstallerLog); n > updatepolicy.MaxDiagnosticInstallerLog {
+		d.InstallerLog = d.InstallerLog[n-updatepolicy.MaxDiagnosticInstallerLog:]
+	}
+}
+
+// agentUpdateTenantIDs resolves which tenants the caller may see rollout
+// records for, narrowed by ?tenant_id=. A nil result means all tenants.
+func agentUpdateTenantIDs(w http.ResponseWriter, r *http.Request) ([]string, bool) {
+	if !authorizeOrReject(w, r, authz.ActionAgentsRead, authz.ResourceRef{}) {
+		return nil, false
+	}
+	scope, ok := tenantScope(getPrincipal(r))
+	if !ok {
+		http.Error(w, "forbidden", http.StatusForbidden)
+		return nil, false
+	}
+	requested := billingTenantIDs(r)
+	if scope == nil {
+		return requested, true
+	}
+	if len(requested) == 0 {
+		for id := range scope {
+			requested = append(requested, id)
+		}
+		sort.Strings(requested)
+		return requested, true
+	}
+	for _, id := range requested {
+		if !tenantAllowed(scope, id) {
+			http.Error(w, "forbidden", http.StatusForbidden)
+			return nil, false
+		}
+	}
+	return requested, true
+}
+
+// handleAgentUpdateRuns lists agent update rollout records
+// (GET ?agent_id=&status=&target_version=&tenant_id=&limit=). With ?id= it
+// returns a single record including its failure diagnostics; lists omit them.
+func handleAgentUpdateRuns(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "GET only", http.StatusMethodNotAllowed)
+		return
+	}
+	tenantIDs, ok := agentUpdateTenantIDs(w, r)
+	if !ok {
+		return
+	}
+	q := r.URL.Query()
+
+	if v := strings.TrimSpace(q.Get("id")); v != "" {
+		id, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			http.Error(w, "invalid id", http.StatusBadRequest)
+			return
+		}
+		run, err := serverStore.GetAgentUpdateRun(r.Context(), id)
+		if errors.Is(err, storage.ErrAgentUpdateRunNotFound) {
+			http.Error(w, "update run not found", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			logError("Failed to load agent update run", "id", id, "error", err)
+			http.Error(w, "failed to load update run", http.StatusInternalServerError)
+			return
+		}
+		if tenantIDs != nil && !slices.Contains(tenantIDs, run.TenantID) {
+			http.Error(w, "update run not found", http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(run)
+		return
+	}
+
+	limit := 200
+	if v := strings.TrimSpace(q.Get("limit")); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			http.Error(w, "invalid limit", http.StatusBadRequest)
+			return
+		}
+		limit = n
+	}
+	runs, err := serverStore.ListAgentUpdateRuns(r.Context(), storage.AgentUpdateRunFilter{
+		AgentID:       strings.TrimSpace(q.Get("agent_id")),
+		Status:        strings.TrimSpace(q.Get("status")),
+		TargetVersion: strings.TrimSpace(q.Get("target_version")),
+		TenantIDs:     tenantIDs,
+		Limit:         limit,
+	})
+	if err != nil {
+		logError("Failed to list agent update runs", "error", err)
+		http.Error(w, "failed to list update runs", http.StatusInternalServerError)
+		return
+	}
+	if runs == nil {
+		runs = []*storage.AgentUpdateRun{}
+	}
+	for _, run := range runs {
+		run.Diagnostics = nil
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(runs)
+}
+
+// handleAgentUpdateFailures clusters failed agent updates by target version
+// and normalized error (GET ?days=&target_version=&tenant_id=), so a bad
+// release shows up as one large cluster rather than many individual failures.
+func handleAgentUpdateFailures(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "GET only", http.StatusMethodNotAllowed)
+		return
+	}
+	tenantIDs, ok := agentUpdateTenantIDs(w, r)
+	if !ok {
+		return
+	}
+	q := r.URL.Query()
+	days := 30
+	if v := strings.TrimSpace(q.Get("days")); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			http.Error(w, "invalid days", http.StatusBadRequest)
+			return
+		}
+		days = n
+	}
+	since := time.Now().UTC().AddDate(0, 0, -days)
+
+	runs, err := serverStore.ListAgentUpdateRuns(r.Context(), storage.AgentUpdateRunFilter{
+		Status:        storage.AgentUpdateStatusFailed,
+		TargetVersion: strings.TrimSpace(q.Get("target_version")),
+		TenantIDs:     tenantIDs,
+		Since:         since,
+		Limit:         10000,
+	})
+	if err != nil {
+		logError("Failed to list failed agent updates", "error", err)
+		http.Error(w, "failed to list update failures", http.StatusInternalServerError)
+		return
+	}
+	clusters := clusterUpdateFailures(runs)
+	if clusters == nil {
+		clusters = []*updateFailureCluster{}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"since":    since,
+		"failures": len(runs),
+		"clusters": clusters,
+	})
+}
@@ -0,0 +1,158 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"printmaster/server/storage"
+)
+
+func TestUpdateErrorSignatureNormalizes(t *testing.T) {
+	t.Parallel()
+	a := updateErrorSignature("APPLY_FAILED", `failed to replace C:\Program Files\PrintMaster\agent.exe: Access is denied (code 5)`)
+	b := updateErrorSignature("APPLY_FAILED", `failed to replace D:\Apps\PM\agent.exe: access is denied (code 5)`)
+	if a != b {
+		t.Fatalf("signatures differ:\n%s\n%s", a, b)
+	}
+	c := updateErrorSignature("DOWNLOAD_FAILED", "Get https://10.0.0.4:9443/api: dial tcp 10.0.0.4:9443: i/o timeout after 30s")
+	d := updateErrorSignature("DOWNLOAD_FAILED", "Get https://10.0.0.9:9443/api: dial tcp 10.0.0.9:9443: i/o timeout after 31s")
+	if c != d {
+		t.Fatalf("signatures differ:\n%s\n%s", c, d)
+	}
+	if a == c {
+		t.Fatal("different failures produced the same signature")
+	}
+	if got := updateErrorSignature("", ""); got != "UNKNOWN" {
+		t.Errorf("empty signature = %q", got)
+	}
+}
+
+func TestClusterUpdateFailures(t *testing.T) {
+	t.Parallel()
+	now := time.Now().UTC()
+	run := func(id int64, agent, version, code, msg string, age time.Duration) *storage.AgentUpdateRun {
+		return &storage.AgentUpdateRun{
+			ID: id, AgentID: agent, TargetVersion: version, Status: storage.AgentUpdateStatusFailed,
+			ErrorCode: code, ErrorMessage: msg, UpdatedAt: now.Add(-age),
+			Diagnostics: &storage.FailureDiagnostics{OS: "windows", Arch: "amd64"},
+		}
+	}
+	clusters := clusterUpdateFailures([]*storage.AgentUpdateRun{
+		run(5, "a1", "1.2.0", "HASH_MISMATCH", "hash mismatch: expected abcdef0123456789abcdef", time.Minute),
+		run(4, "a2", "1.2.0", "APPLY_FAILED", "access denied (code 5)", 2*time.Minute),
+		run(3, "a3", "1.2.0", "APPLY_FAILED", "access denied (code 5)", 3*time.Minute),
+		run(2, "a3", "1.2.0", "APPLY_FAILED", "access denied (code 5)", 4*time.Minute),
+		run(1, "a4", "1.1.0", "APPLY_FAILED", "access denied (code 5)", 5*time.Minute),
+	})
+	if len(clusters) != 3 {
+		t.Fatalf("len(clusters) = %d, want 3", len(clusters))
+	}
+	top := clusters[0]
+	if top.TargetVersion != "1.2.0" || top.ErrorCode != "APPLY_FAILED" || top.AgentCount != 2 || top.RunCount != 3 {
+		t.Fatalf("unexpected top cluster: %+v", top)
+	}
+	if top.SampleRunID != 4 || top.Platforms["windows/amd64"] != 3 {
+		t.Errorf("unexpected sample/platforms: %+v", top)
+	}
+	if clusters[1].AgentCount != 1 || clusters[1].ErrorCode != "HASH_MISMATCH" {
+		t.Errorf("clusters[1] = %+v, want most recent single-agent cluster", clusters[1])
+	}
+}
+
+func TestHandleAgentUpdateTelemetryRecordsFailures(t *testing.T) {
+	store := SetupTestStore(t)
+	ctx := context.Background()
+
+	post := func(agent *storage.Agent, payload map[string]interface{}) {
+		t.Helper()
+		body, _ := json.Marshal(payload)
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/agents/update/telemetry", bytes.NewReader(body))
+		req = req.WithContext(context.WithValue(req.Context(), agentContextKey, agent))
+		rr := httptest.NewRecorder()
+		handleAgentUpdateTelemetry(rr, req)
+		if rr.Code != http.StatusOK {
+			t.Fatalf("expected 200, got %d: %s", rr.Code, rr.Body.String())
+		}
+	}
+
+	for i, tenant := range []string{"tenant-a", "tenant-a", "tenant-b"} {
+		agent := &storage.Agent{
+			AgentID:      fmt.Sprintf("agent-up-%d", i),
+			Hostname:     "host",
+			Token:        "token",
+			TenantID:     tenant,
+			RegisteredAt: time.Now(),
+			LastSeen:     time.Now(),
+			Status:       "active",
+		}
+		if err := store.RegisterAgent(ctx, agent); err != nil {
+			t.Fatalf("RegisterAgent: %v", err)
+		}
+		runID := fmt.Sprintf("run-%d", i)
+		post(agent, map[string]interface{}{"run_id": runID, "status": "downloading", "current_version": "1.0.0", "target_version": "1.1.0"})
+		post(agent, map[string]interface{}{
+			"run_id": runID, "status": "failed", "current_version": "1.0.0", "target_version": "1.1.0",
+			"error_code":    "APPLY_FAILED",
+			"error_message": fmt.Sprintf(`open C:\ProgramData\PrintMaster\agent\autoupdate\staging\agent-%d.exe: access denied`, i),
+			"diagnostics":   map[string]interface{}{"os": "windows", "arch": "amd64", "log_tail": []string{"line 1", "line 2"}},
+		})
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/agents/update/failures", nil)
+	req = InjectTestAdmin(req)
+	rr := httptest.NewRecorder()
+	handleAgentUpdateFailures(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	var resp struct {
+		Failures int                     `json:"failures"`
+		Clusters []*updateFailureCluster `json:"clusters"`
+	}
+	if err := json.NewDecoder(rr.Body).Decode(&resp); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if resp.Failures != 3 || len(resp.Clusters) != 1 || resp.Clusters[0].AgentCount != 3 {
+		t.Fatalf("unexpected failures response: %+v", resp)
+	}
+
+	// Tenant-scoped users only see their tenant's failures
+	req = httptest.NewRequest(http.MethodGet, "/api/v1/agents/update/failures", nil)
+	req = InjectTestUser(req, NewTestUser(storage.RoleViewer, "tenant-b"))
+	rr = httptest.NewRecorder()
+	handleAgentUpdateFailures(rr, req)
+	resp.Clusters = nil
+	if err := json.NewDecoder(rr.Body).Decode(&resp); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if resp.Failures != 1 || len(resp.Clusters) != 1 || resp.Clusters[0].AgentIDs[0] != "agent-up-2" {
+		t.Fatalf("unexpected scoped response: %+v", resp)
+	}
+
+	// The single-run view carries the diagnostics; tenant scoping hides others
+	req = httptest.NewRequest(http.MethodGet, fmt.Sprintf("/api/v1/agents/update/runs?id=%d", resp.Clusters[0].SampleRunID), nil)
+	req = InjectTestAdmin(req)
+	rr = httptest.NewRecorder()
+	handleAgentUpdateRuns(rr, req)
+	var run storage.AgentUpdateRun
+	if err := json.NewDecoder(rr.Body).Decode(&run); err != nil {
+		t.Fatalf("decode run: %v", err)
+	}
+	if run.Diagnostics == nil || len(run.Diagnostics.LogTail) != 2 || run.Status != storage.AgentUpdateStatusFailed {
+		t.Fatalf("unexpected run: %+v", run)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, fmt.Sprintf("/api/v1/agents/update/runs?id=%d", run.ID), nil)
+	req = InjectTestUser(req, NewTestUser(storage.RoleViewer, "tenant-a"))
+	rr = httptest.NewRecorder()
+	handleAgentUpdateRuns(rr, req)
+	if rr.Code != http.StatusNotFound {
+		t.Fatalf("expected 404 for other tenant's run, got %d", rr.Code)
+	}
+}
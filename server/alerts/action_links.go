@@ -0,0 +1,163 @@
+package alerts
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Alert action links let the recipient of a notification email acknowledge
+// or snooze an alert without logging in. The link carries an HMAC-signed
+// token naming the alert, the action and an expiry; possession of the email
+// is the authorization, so tokens only ever allow these two low-risk actions.
+
+// Actions an alert action link can carry.
+const (
+	AlertLinkActionAcknowledge = "ack"
+	AlertLinkActionSnooze      = "snooze"
+)
+
+// Defaults used when the notifier config leaves them unset.
+const (
+	DefaultActionLinkTTL  = 7 * 24 * time.Hour
+	DefaultSnoozeDuration = 4 * time.Hour
+)
+
+// AlertActionPath is the public endpoint action links point at.
+const AlertActionPath = "/api/v1/alerts/action"
+
+// ErrInvalidActionToken is returned for malformed, tampered or expired tokens.
+var ErrInvalidActionToken = errors.New("invalid or expired alert action link")
+
+// AlertActionToken is the signed content of an action link.
+type AlertActionToken struct {
+	AlertID   int64
+	Action    string
+	Minutes   int // snooze length; zero for acknowledge
+	ExpiresAt time.Time
+}
+
+// SignAlertAction returns the URL-safe token for t.
+func SignAlertAction(key []byte, t AlertActionToken) string {
+	payload := fmt.Sprintf("v1|%d|%s|%d|%d", t.AlertID, t.Action, t.Minutes, t.ExpiresAt.Unix())
+	enc := base64.RawURLEncoding
+	return enc.EncodeToString([]byte(payload)) + "." + enc.EncodeToString(actionMAC(key, "link", payload))
+}
+
+// VerifyAlertAction checks token's signature and expiry and returns its
+// content.
+func VerifyAlertAction(key []byte, token string, now time.Time) (*AlertActionToken, error) {
+	if len(key) == 0 {
+		return nil, ErrInvalidActionToken
+	}
+	encPayload, encSig, ok := strings.Cut(strings.TrimSpace(token), ".")
+	if !ok {
+		return nil, ErrInvalidActionToken
+	}
+	enc := base64.RawURLEncoding
+	payload, err := enc.DecodeString(encPayload)
+	if err != nil {
+		return nil, ErrInvalidActionToken
+	}
+	sig, err := enc.DecodeString(encSig)
+	if err != nil || !hmac.Equal(sig, actionMAC(key, "link", string(payload))) {
+		return nil, ErrInvalidActionToken
+	}
+
+	parts := strings.Split(string(payload), "|")
+	if len(parts) != 5 || parts[0] != "v1" {
+		return nil, ErrInvalidActionToken
+	}
+	id, err1 := strconv.ParseInt(parts[1], 10, 64)
+	minutes, err2 := strconv.Atoi(parts[3])
+	expires, err3 := strconv.ParseInt(parts[4], 10, 64)
+	if err1 != nil || err2 != nil || err3 != nil {
+		return nil, ErrInvalidActionToken
+	}
+	t := &AlertActionToken{AlertID: id, Action: parts[2], Minutes: minutes, ExpiresAt: time.Unix(expires, 0).UTC()}
+	switch t.Action {
+	case AlertLinkActionAcknowledge:
+	case AlertLinkActionSnooze:
+		if t.Minutes <= 0 {
+			return nil, ErrInvalidActionToken
+		}
+	default:
+		return nil, ErrInvalidActionToken
+	}
+	if !now.Before(t.ExpiresAt) {
+		return nil, ErrInvalidActionToken
+	}
+	return t, nil
+}
+
+func actionMAC(key []byte, purpose, payload string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte("printmaster-alert-" + purpose + "|" + payload))
+	return mac.Sum(nil)
+}
+
+// replyTagPattern matches the tag alert emails carry in their subject so a
+// reply can be tied back to the alert, e.g. "[PM-A42-1f2e3d4c5b]".
+var replyTagPattern = regexp.MustCompile(`\[PM-A(\d+)-([0-9a-f]{10})\]`)
+
+// AlertReplyTag returns the subject tag that lets a reply acknowledge alertID.
+func AlertReplyTag(key []byte, alertID int64) string {
+	sig := actionMAC(key, "reply", strconv.FormatInt(alertID, 10))
+	return fmt.Sprintf("[PM-A%d-%s]", alertID, hex.EncodeToString(sig)[:10])
+}
+
+// ParseAlertReplyTag finds a correctly signed reply tag in subject and
+// returns the alert ID it names.
+func ParseAlertReplyTag(key []byte, subject string) (int64, bool) {
+	if len(key) == 0 {
+		return 0, false
+	}
+	for _, m := range replyTagPattern.FindAllStringSubmatch(subject, -1) {
+		id, err := strconv.ParseInt(m[1], 10, 64)
+		if err != nil {
+			continue
+		}
+		if hmac.Equal([]byte(AlertReplyTag(key, id)), []byte(m[0])) {
+			return id, true
+		}
+	}
+	return 0, false
+}
+
+// actionLinks returns the acknowledge and snooze links for alertID, or empty
+// strings when no public URL or signing key is configured.
+func (n *Notifier) actionLinks(alertID int64) (ack, snooze string) {
+	base := strings.TrimRight(strings.TrimSpace(n.config.BaseURL), "/")
+	if base == "" || len(n.config.ActionLinkKey) == 0 || alertID == 0 {
+		return "", ""
+	}
+	expires := time.Now().Add(n.config.ActionLinkTTL)
+	link := func(action string, minutes int) string {
+		token := SignAlertAction(n.config.ActionLinkKey, AlertActionToken{AlertID: alertID, Action: action, Minutes: minutes, ExpiresAt: expires})
+		return base + AlertActionPath + "?token=" + url.QueryEscape(token)
+	}
+	return link(AlertLinkActionAcknowledge, 0), link(AlertLinkActionSnooze, int(n.config.SnoozeDuration/time.Minute))
+}
+
+// VerifyActionToken checks a token from an action link against this
+// notifier's key.
+func (n *Notifier) VerifyActionToken(token string) (*AlertActionToken, error) {
+	return VerifyAlertAction(n.config.ActionLinkKey, token, time.Now())
+}
+
+// AlertIDFromReply returns the alert a reply subject refers to, when reply
+// acknowledgement is enabled and the subject carries a valid tag.
+func (n *Notifier) AlertIDFromReply(subject string) (int64, bool) {
+	if n == nil || n.config.ReplyTo == "" {
+		return 0, false
+	}
+	return ParseAlertReplyTag(n.config.ActionLinkKey, subject)
+}
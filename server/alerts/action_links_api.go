@@ -0,0 +1,246 @@
+package alerts
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	htmltemplate "html/template"
+	"net/http"
+	"time"
+
+	"printmaster/server/authz"
+	"printmaster/server/storage"
+)
+
+// AlertActionStore is what applying an acknowledge or snooze action needs.
+type AlertActionStore interface {
+	GetAlert(context.Context, int64) (*storage.Alert, error)
+	ListChildAlerts(context.Context, int64) ([]storage.Alert, error)
+	AcknowledgeAlert(context.Context, int64, string) error
+	SuppressAlert(context.Context, int64, time.Time) error
+}
+
+// Errors returned by ApplyAlertAction.
+var (
+	ErrAlertNotFound = errors.New("alert not found")
+	ErrAlertClosed   = errors.New("alert is no longer open")
+)
+
+// ApplyAlertAction acknowledges or snoozes an alert (and the open children of
+// an incident) on behalf of actor, returning the alert as it was before the
+// change.
+func ApplyAlertAction(ctx context.Context, store AlertActionStore, id int64, action string, snooze time.Duration, actor string) (*storage.Alert, error) {
+	alert, err := store.GetAlert(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if alert == nil {
+		return nil, ErrAlertNotFound
+	}
+	switch alert.Status {
+	case storage.AlertStatusActive, storage.AlertStatusAcknowledged, storage.AlertStatusSuppressed:
+	default:
+		return alert, ErrAlertClosed
+	}
+
+	apply := func(alertID int64) error {
+		if action == AlertLinkActionSnooze {
+			return store.SuppressAlert(ctx, alertID, time.Now().UTC().Add(snooze))
+		}
+		return store.AcknowledgeAlert(ctx, alertID, actor)
+	}
+	if alert.Type == storage.AlertTypeIncident {
+		children, err := store.ListChildAlerts(ctx, id)
+		if err != nil {
+			return alert, err
+		}
+		for _, c := range children {
+			if c.Status == storage.AlertStatusActive || c.Status == storage.AlertStatusAcknowledged {
+				if err := apply(c.ID); err != nil {
+					return alert, err
+				}
+			}
+		}
+	}
+	return alert, apply(id)
+}
+
+var alertActionPage = htmltemplate.Must(htmltemplate.New("alert-action").Parse(`<!DOCTYPE html>
+<html lang="en"><head><meta charset="utf-8"><meta name="viewport" content="width=device-width, initial-scale=1">
+<meta name="robots" content="noindex"><title>PrintMaster alert</title>
+<style>body{font-family:system-ui,sans-serif;background:#1e1e1e;color:#ddd;display:flex;justify-content:center;padding:48px 16px}
+main{max-width:480px;background:#2a2a2a;border-radius:8px;padding:24px}h1{font-size:1.2em;margin-top:0}
+button{background:#0e639c;color:#fff;border:0;border-radius:4px;padding:10px 18px;font-size:1em;cursor:pointer}
+.muted{color:#999;font-size:.9em}</style></head>
+<body><main>
+<h1>{{.Heading}}</h1>
+{{if .Alert}}<p><strong>{{.Alert}}</strong></p>{{end}}
+<p>{{.Message}}</p>
+{{if .Token}}<form method="post"><input type="hidden" name="token" value="{{.Token}}"><button type="submit">{{.Button}}</button></form>{{end}}
+<p class="muted">PrintMaster</p>
+</main></body></html>
+`))
+
+type alertActionView struct {
+	Heading string
+	Alert   string
+	Message string
+	Token   string
+	Button  string
+}
+
+func writeAlertActionPage(w http.ResponseWriter, status int, view alertActionView) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("Referrer-Policy", "no-referrer")
+	w.WriteHeader(status)
+	_ = alertActionPage.Execute(w, view)
+}
+
+func describeSnooze(minutes int) string {
+	d := time.Duration(minutes) * time.Minute
+	if d%time.Hour == 0 {
+		return fmt.Sprintf("%d hour(s)", int(d/time.Hour))
+	}
+	return fmt.Sprintf("%d minute(s)", minutes)
+}
+
+// handleAlertActionLink serves the one-click links from alert emails. It is
+// public: the signed token authorizes the request. GET only shows a
+// confirmation page because mail scanners prefetch links; the action is
+// applied by the POST the page submits.
+func (api *API) handleAlertActionLink(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodPost {
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+	if api.notifier == nil {
+		http.NotFound(w, r)
+		return
+	}
+	token := r.URL.Query().Get("token")
+	if r.Method == http.MethodPost {
+		if err := r.ParseForm(); err == nil && r.PostForm.Get("token") != "" {
+			token = r.PostForm.Get("token")
+		}
+	}
+	t, err := api.notifier.VerifyActionToken(token)
+	if err != nil {
+		writeAlertActionPage(w, http.StatusForbidden, alertActionView{
+			Heading: "Link not valid",
+			Message: "This link is invalid or has expired. Sign in to PrintMaster to manage the alert.",
+		})
+		return
+	}
+
+	verb, button := "acknowledge", "Acknowledge alert"
+	if t.Action == AlertLinkActionSnooze {
+		verb, button = "snooze for "+describeSnooze(t.Minutes), "Snooze alert"
+	}
+
+	if r.Method == http.MethodGet {
+		alert, err := api.store.GetAlert(r.Context(), t.AlertID)
+		if err != nil || alert == nil {
+			writeAlertActionPage(w, http.StatusNotFound, alertActionView{Heading: "Alert not found", Message: "The alert no longer exists."})
+			return
+		}
+		writeAlertActionPage(w, http.StatusOK, alertActionView{
+			Heading: "Confirm alert action",
+			Alert:   alert.Title,
+			Message: fmt.Sprintf("Do you want to %s this alert?", verb),
+			Token:   token,
+			Button:  button,
+		})
+		return
+	}
+
+	const actor = "email link"
+	alert, err := ApplyAlertAction(r.Context(), api.store, t.AlertID, t.Action, time.Duration(t.Minutes)*time.Minute, actor)
+	switch {
+	case errors.Is(err, ErrAlertNotFound):
+		writeAlertActionPage(w, http.StatusNotFound, alertActionView{Heading: "Alert not found", Message: "The alert no longer exists."})
+		return
+	case errors.Is(err, ErrAlertClosed):
+		writeAlertActionPage(w, http.StatusConflict, alertActionView{
+			Heading: "Nothing to do",
+			Alert:   alert.Title,
+			Message: fmt.Sprintf("This alert is already %s.", alert.Status),
+		})
+		return
+	case err != nil:
+		writeAlertActionPage(w, http.StatusInternalServerError, alertActionView{Heading: "Something went wrong", Message: "The alert could not be updated. Try again or sign in to PrintMaster."})
+		return
+	}
+
+	action, details, done := "alert.acknowledge", "Acknowledged alert via email link", "The alert has been acknowledged."
+	if t.Action == AlertLinkActionSnooze {
+		action = "alert.snooze"
+		details = fmt.Sprintf("Snoozed alert for %s via email link", describeSnooze(t.Minutes))
+		done = fmt.Sprintf("The alert is snoozed for %s.", describeSnooze(t.Minutes))
+	}
+	api.audit(r, &storage.AuditEntry{
+		ActorType:  storage.AuditActorSystem,
+		ActorID:    "email-link",
+		ActorName:  actor,
+		Action:     action,
+		TargetType: "alert",
+		TargetID:   fmt.Sprintf("%d", t.AlertID),
+		TenantID:   alert.TenantID,
+		Details:    details,
+		Metadata:   map[string]interface{}{"via": "email_link", "snooze_minutes": t.Minutes},
+	})
+	writeAlertActionPage(w, http.StatusOK, alertActionView{Heading: "Done", Alert: alert.Title, Message: done})
+}
+
+// handleSnoozeAlert suppresses an alert for the requested number of minutes
+// (default four hours). The evaluator returns it to active afterwards.
+func (api *API) handleSnoozeAlert(w http.ResponseWriter, r *http.Request, id int64) {
+	if r.Method != http.MethodPost {
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+	if !api.authorize(w, r, authz.ActionSettingsAlertsWrite, authz.ResourceRef{}) {
+		return
+	}
+
+	var req struct {
+		Minutes int `json:"minutes"`
+	}
+	if r.ContentLength != 0 {
+		if err := decodeJSON(r.Body, &req); err != nil {
+			writeError(w, http.StatusBadRequest, "invalid request body")
+			return
+		}
+	}
+	if req.Minutes == 0 {
+		req.Minutes = int(DefaultSnoozeDuration / time.Minute)
+	}
+	if req.Minutes < 1 || req.Minutes > 30*24*60 {
+		writeError(w, http.StatusBadRequest, "minutes must be between 1 and 43200")
+		return
+	}
+
+	_, err := ApplyAlertAction(r.Context(), api.store, id, AlertLinkActionSnooze, time.Duration(req.Minutes)*time.Minute, api.actorLabel(r))
+	if errors.Is(err, ErrAlertNotFound) {
+		writeError(w, http.StatusNotFound, "alert not found")
+		return
+	}
+	if errors.Is(err, ErrAlertClosed) {
+		writeError(w, http.StatusConflict, err.Error())
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to snooze alert")
+		return
+	}
+
+	api.audit(r, &storage.AuditEntry{
+		Action:     "alert.snooze",
+		TargetType: "alert",
+		TargetID:   fmt.Sprintf("%d", id),
+		Details:    fmt.Sprintf("Snoozed alert for %s (%s)", describeSnooze(req.Minutes), api.actorLabel(r)),
+		Metadata:   map[string]interface{}{"snooze_minutes": req.Minutes},
+	})
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{"status": storage.AlertStatusSuppressed, "minutes": req.Minutes})
+}
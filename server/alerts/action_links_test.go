@@ -0,0 +1,152 @@
+package alerts
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"printmaster/server/storage"
+)
+
+func TestAlertActionTokenRoundTrip(t *testing.T) {
+	t.Parallel()
+
+	key := []byte("0123456789abcdef0123456789abcdef")
+	now := time.Now()
+	token := SignAlertAction(key, AlertActionToken{AlertID: 42, Action: AlertLinkActionSnooze, Minutes: 240, ExpiresAt: now.Add(time.Hour)})
+
+	got, err := VerifyAlertAction(key, token, now)
+	if err != nil {
+		t.Fatalf("VerifyAlertAction: %v", err)
+	}
+	if got.AlertID != 42 || got.Action != AlertLinkActionSnooze || got.Minutes != 240 {
+		t.Fatalf("unexpected token content: %+v", got)
+	}
+
+	if _, err := VerifyAlertAction(key, token, now.Add(2*time.Hour)); err == nil {
+		t.Error("expired token should be rejected")
+	}
+	if _, err := VerifyAlertAction([]byte("another key"), token, now); err == nil {
+		t.Error("token signed with another key should be rejected")
+	}
+	forged := SignAlertAction([]byte("another key"), AlertActionToken{AlertID: 43, Action: AlertLinkActionAcknowledge, ExpiresAt: now.Add(time.Hour)})
+	payload, _, _ := strings.Cut(forged, ".")
+	_, sig, _ := strings.Cut(token, ".")
+	if _, err := VerifyAlertAction(key, payload+"."+sig, now); err == nil {
+		t.Error("token with swapped payload should be rejected")
+	}
+}
+
+func TestAlertReplyTag(t *testing.T) {
+	t.Parallel()
+
+	key := []byte("0123456789abcdef0123456789abcdef")
+	tag := AlertReplyTag(key, 7)
+	if id, ok := ParseAlertReplyTag(key, "RE: [WARNING] Toner low "+tag); !ok || id != 7 {
+		t.Fatalf("ParseAlertReplyTag = %d, %v", id, ok)
+	}
+	if _, ok := ParseAlertReplyTag(key, "RE: "+strings.Replace(tag, "PM-A7-", "PM-A8-", 1)); ok {
+		t.Error("tag with another alert id should not verify")
+	}
+	if _, ok := ParseAlertReplyTag(key, "Scan from MFP"); ok {
+		t.Error("subject without a tag should not match")
+	}
+}
+
+func TestAlertActionLinkEndpoint(t *testing.T) {
+	t.Parallel()
+
+	store, err := storage.NewSQLiteStore(":memory:")
+	if err != nil {
+		t.Fatalf("NewSQLiteStore: %v", err)
+	}
+	defer store.Close()
+	ctx := context.Background()
+	id, err := store.CreateAlert(ctx, &storage.Alert{
+		Type: storage.AlertTypeSupplyLow, Severity: storage.AlertSeverityWarning, Scope: storage.AlertScopeDevice,
+		Status: storage.AlertStatusActive, DeviceSerial: "SN1", Title: "Toner low", TriggeredAt: time.Now().UTC(),
+	})
+	if err != nil {
+		t.Fatalf("CreateAlert: %v", err)
+	}
+
+	notifier := NewNotifier(store, NotifierConfig{BaseURL: "https://pm.example.com", ActionLinkKey: []byte("0123456789abcdef0123456789abcdef")})
+	var audited []*storage.AuditEntry
+	api, err := NewAPI(store, APIOptions{Notifier: notifier, AuditLogger: func(r *http.Request, e *storage.AuditEntry) { audited = append(audited, e) }})
+	if err != nil {
+		t.Fatalf("NewAPI: %v", err)
+	}
+	mux := http.NewServeMux()
+	api.RegisterRoutes(RouteConfig{Mux: mux, FeatureEnabled: true})
+
+	data := notifier.TemplateData(ctx, &storage.Alert{ID: id})
+	if !strings.HasPrefix(data.Links.Acknowledge, "https://pm.example.com"+AlertActionPath+"?token=") || data.Links.Snooze == "" {
+		t.Fatalf("unexpected action links: %+v", data.Links)
+	}
+	link, _ := url.Parse(data.Links.Acknowledge)
+
+	// GET only confirms; mail scanners prefetching the link change nothing
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, link.RequestURI(), nil))
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), "<form method=\"post\"") {
+		t.Fatalf("GET: status %d body %s", rec.Code, rec.Body.String())
+	}
+	if got, _ := store.GetAlert(ctx, id); got.Status != storage.AlertStatusActive {
+		t.Fatalf("GET changed alert status to %q", got.Status)
+	}
+
+	rec = httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, link.RequestURI(), nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("POST: status %d body %s", rec.Code, rec.Body.String())
+	}
+	got, _ := store.GetAlert(ctx, id)
+	if got.Status != storage.AlertStatusAcknowledged || got.AcknowledgedBy != "email link" {
+		t.Fatalf("alert not acknowledged: status %q by %q", got.Status, got.AcknowledgedBy)
+	}
+	if len(audited) != 1 || audited[0].Action != "alert.acknowledge" || audited[0].ActorType != storage.AuditActorSystem {
+		t.Fatalf("unexpected audit entries: %+v", audited)
+	}
+
+	snooze, _ := url.Parse(data.Links.Snooze)
+	rec = httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, snooze.RequestURI(), nil))
+	if got, _ := store.GetAlert(ctx, id); rec.Code != http.StatusOK || got.Status != storage.AlertStatusSuppressed || got.SuppressedUntil == nil {
+		t.Fatalf("snooze: status %d, alert %+v", rec.Code, got)
+	}
+
+	rec = httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, AlertActionPath+"?token=bogus", nil))
+	if rec.Code != http.StatusForbidden {
+		t.Fatalf("invalid token: expected 403, got %d", rec.Code)
+	}
+}
+
+func TestRenderForChannel_ReplyTag(t *testing.T) {
+	t.Parallel()
+
+	key := []byte("0123456789abcdef0123456789abcdef")
+	n := NewNotifier(newMockNotifierStore(), NotifierConfig{BaseURL: "https://pm.example.com", ActionLinkKey: key, ReplyTo: "alerts@pm.example.com"})
+	alert := &storage.Alert{ID: 9, Severity: storage.AlertSeverityWarning, Title: "Toner low", TriggeredAt: time.Now()}
+
+	msg, err := n.renderForChannel(context.Background(), storage.ChannelTypeEmail, alert)
+	if err != nil {
+		t.Fatalf("renderForChannel: %v", err)
+	}
+	if msg.ReplyTo != "alerts@pm.example.com" || !strings.HasSuffix(msg.Subject, AlertReplyTag(key, 9)) {
+		t.Fatalf("unexpected reply metadata: reply-to %q subject %q", msg.ReplyTo, msg.Subject)
+	}
+	if !strings.Contains(msg.Text, "Acknowledge: https://pm.example.com"+AlertActionPath) {
+		t.Errorf("email body missing acknowledge link:\n%s", msg.Text)
+	}
+	if id, ok := n.AlertIDFromReply("RE: " + msg.Subject); !ok || id != 9 {
+		t.Errorf("AlertIDFromReply = %d, %v", id, ok)
+	}
+	if raw := string(buildEmailMessage("a@example.com", []string{"b@example.com"}, msg)); !strings.Contains(raw, "\r\nReply-To: alerts@pm.example.com\r\n") {
+		t.Errorf("message missing Reply-To header:\n%s", raw)
+	}
+}
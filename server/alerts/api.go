@@ -25,6 +25,8 @@ type Store interface {
 	UpdateAlertStatus(context.Context, int64, storage.AlertStatus) error
 	AcknowledgeAlert(context.Context, int64, string) error
 	ResolveAlert(context.Context, int64) error
+	SuppressAlert(context.Context, int64, time.Time) error
+	ListChildAlerts(context.Context, int64) ([]storage.Alert, error)
 
 	// Alert Rules CRUD
 	CreateAlertRule(context.Context, *storage.AlertRule) (int64, error)
@@ -40,6 +42,12 @@ type Store interface {
 	UpdateNotificationChannel(context.Context, *storage.NotificationChannel) error
 	DeleteNotificationChannel(context.Context, int64) error
 
+	// Notification Templates
+	UpsertNotificationTemplate(context.Context, *storage.NotificationTemplate) error
+	GetNotificationTemplate(context.Context, int64) (*storage.NotificationTemplate, error)
+	ListNotificationTemplates(context.Context) ([]*storage.NotificationTemplate, error)
+	DeleteNotificationTemplate(context.Context, int64) error
+
 	// Escalation Policies CRUD
 	CreateEscalationPolicy(context.Context, *storage.EscalationPolicy) (int64, error)
 	GetEscalationPolicy(context.Context, int64) (*storage.EscalationPolicy, error)
@@ -122,6 +130,8 @@ func (api *API) RegisterRoutes(cfg RouteConfig) {
 	// Alerts CRUD
 	mux.HandleFunc("/api/v1/alerts", wrap(api.handleAlerts))
 	mux.HandleFunc("/api/v1/alerts/", wrap(api.handleAlertRoute))
+	// One-click email links are authorized by their signed token, not a session
+	mux.HandleFunc(AlertActionPath, api.handleAlertActionLink)
 
 	// Alert Rules CRUD
 	mux.HandleFunc("/api/v1/alert-rules", wrap(api.handleAlertRules))
@@ -132,6 +142,12 @@ func (api *API) RegisterRoutes(cfg RouteConfig) {
 	mux.HandleFunc("/api/v1/notification-channels/test", wrap(api.handleTestNotificationChannel))
 	mux.HandleFunc("/api/v1/notification-channels/", wrap(api.handleNotificationChannelRoute))
 
+	// Notification templates
+	mux.HandleFunc("/api/v1/notification-templates", wrap(api.handleNotificationTemplates))
+	mux.HandleFunc("/api/v1/notification-templates/preview", wrap(api.handlePreviewNotificationTemplate))
+	mux.HandleFunc("/api/v1/notification-templates/test", wrap(api.handleTestNotificationTemplate))
+	mux.HandleFunc("/api/v1/notification-templates/", wrap(api.handleNotificationTemplateRoute))
+
 	// Escalation Policies CRUD
 	mux.HandleFunc("/api/v1/escalation-policies", wrap(api.handleEscalationPolicies))
 	mux.HandleFunc("/api/v1/escalation-policies/", wrap(api.handleEscalationPolicyRoute))
@@ -244,6 +260,9 @@ func (api *API) handleListAlerts(w http.ResponseWriter, r *http.Request) {
 	if tenantID := r.URL.Query().Get("tenant_id"); tenantID != "" {
 		filters.TenantID = tenantID
 	}
+	if topLevel, err := strconv.ParseBool(r.URL.Query().Get("top_level")); err == nil {
+		filters.TopLevel = topLevel
+	}
 	if limit := r.URL.Query().Get("limit"); limit != "" {
 		if l, err := strconv.Atoi(limit); err == nil && l > 0 {
 			filters.Limit = l
@@ -348,6 +367,10 @@ func (api *API) handleAlertRoute(w http.ResponseWriter, r *http.Request) {
 			api.handleAcknowledgeAlert(w, r, id)
 		case "resolve":
 			api.handleResolveAlert(w, r, id)
+		case "snooze":
+			api.handleSnoozeAlert(w, r, id)
+		case "children":
+			api.handleListChildAlerts(w, r, id)
 		default:
 			http.NotFound(w, r)
 		}
@@ -383,6 +406,48 @@ func (api *API) handleGetAlert(w http.ResponseWriter, r *http.Request, id int64)
 	writeJSON(w, http.StatusOK, alert)
 }
 
+// handleListChildAlerts returns the alerts grouped under an incident.
+func (api *API) handleListChildAlerts(w http.ResponseWriter, r *http.Request, id int64) {
+	if r.Method != http.MethodGet {
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+	if !api.authorize(w, r, authz.ActionSettingsAlertsRead, authz.ResourceRef{}) {
+		return
+	}
+
+	children, err := api.store.ListChildAlerts(r.Context(), id)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to list child alerts")
+		return
+	}
+	if children == nil {
+		children = []storage.Alert{}
+	}
+
+	writeJSON(w, http.StatusOK, children)
+}
+
+// incidentChildIDs returns the open child alerts of an incident so
+// acknowledging or resolving the incident applies to the whole group.
+func (api *API) incidentChildIDs(r *http.Request, id int64) []int64 {
+	alert, err := api.store.GetAlert(r.Context(), id)
+	if err != nil || alert == nil || alert.Type != storage.AlertTypeIncident {
+		return nil
+	}
+	children, err := api.store.ListChildAlerts(r.Context(), id)
+	if err != nil {
+		return nil
+	}
+	var ids []int64
+	for _, c := range children {
+		if c.Status == storage.AlertStatusActive || c.Status == storage.AlertStatusAcknowledged {
+			ids = append(ids, c.ID)
+		}
+	}
+	return ids
+}
+
 func (api *API) handleDeleteAlert(w http.ResponseWriter, r *http.Request, id int64) {
 	if !api.authorize(w, r, authz.ActionSettingsAlertsWrite, authz.ResourceRef{}) {
 		return
@@ -414,6 +479,12 @@ func (api *API) handleAcknowledgeAlert(w http.ResponseWriter, r *http.Request, i
 	}
 
 	acknowledgedBy := api.actorLabel(r)
+	for _, childID := range api.incidentChildIDs(r, id) {
+		if err := api.store.AcknowledgeAlert(r.Context(), childID, acknowledgedBy); err != nil {
+			writeError(w, http.StatusInternalServerError, "failed to acknowledge alert")
+			return
+		}
+	}
 	if err := api.store.AcknowledgeAlert(r.Context(), id, acknowledgedBy); err != nil {
 		writeError(w, http.StatusInternalServerError, "failed to acknowledge alert")
 		return
@@ -438,6 +509,12 @@ func (api *API) handleResolveAlert(w http.ResponseWriter, r *http.Request, id in
 		return
 	}
 
+	for _, childID := range api.incidentChildIDs(r, id) {
+		if err := api.store.ResolveAlert(r.Context(), childID); err != nil {
+			writeError(w, http.StatusInternalServerError, "failed to resolve alert")
+			return
+		}
+	}
 	if err := api.store.ResolveAlert(r.Context(), id); err != nil {
 		writeError(w, http.StatusInternalServerError, "failed to resolve alert")
 		return
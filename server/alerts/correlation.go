@@ -0,0 +1,373 @@
+package alerts
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	"printmaster/server/storage"
+)
+
+// floodCorrelationKey identifies the fleet-wide incident that collects
+// alerts once the flood threshold is exceeded.
+const floodCorrelationKey = "flood"
+
+// incidentDetails is stored in an incident's Details so later evaluation
+// passes can attach new alerts to the open incident for the same group.
+type incidentDetails struct {
+	CorrelationKey string `json:"correlation_key"`
+	Rule           string `json:"rule,omitempty"`
+	GroupBy        string `json:"group_by,omitempty"`
+	Group          string `json:"group,omitempty"`
+}
+
+func parseIncidentDetails(raw string) incidentDetails {
+	var d incidentDetails
+	if raw != "" {
+		_ = json.Unmarshal([]byte(raw), &d)
+	}
+	return d
+}
+
+// correlationGroup collects the alerts of one evaluation pass that share a
+// correlation rule and group value.
+type correlationGroup struct {
+	rule     storage.AlertCorrelationRule
+	name     string
+	key      string
+	alerts   []*storage.Alert
+	existing []int64 // ungrouped alerts already raised within the window
+}
+
+// floodGate tracks how many new top-level alerts may still be raised in the
+// current flood window.
+type floodGate struct {
+	enabled    bool
+	remaining  int
+	incidentID int64
+}
+
+func newFloodGate(settings *storage.AlertSettings, active []storage.Alert, now time.Time) *floodGate {
+	g := &floodGate{}
+	if settings == nil || !settings.FloodSuppressionEnabled || settings.FloodThreshold <= 0 {
+		return g
+	}
+	window := time.Duration(settings.FloodWindowMins) * time.Minute
+	if window <= 0 {
+		window = 10 * time.Minute
+	}
+	g.enabled = true
+	g.remaining = settings.FloodThreshold
+	for _, a := range active {
+		if a.Type == storage.AlertTypeIncident && parseIncidentDetails(a.Details).CorrelationKey == floodCorrelationKey {
+			g.incidentID = a.ID
+			continue
+		}
+		if a.ParentAlertID == nil && now.Sub(a.TriggeredAt) <= window {
+			g.remaining--
+		}
+	}
+	return g
+}
+
+// allow consumes one top-level alert from the budget.
+func (g *floodGate) allow() bool {
+	if !g.enabled {
+		return true
+	}
+	if g.remaining > 0 {
+		g.remaining--
+		return true
+	}
+	return false
+}
+
+// correlationRules returns the enabled correlation rules, or nil when
+// grouping is disabled.
+func correlationRules(settings *storage.AlertSettings) []storage.AlertCorrelationRule {
+	if settings == nil || !settings.GroupingEnabled {
+		return nil
+	}
+	rules := settings.CorrelationRules
+	if len(rules) == 0 {
+		rules = storage.DefaultCorrelationRules()
+	}
+	enabled := make([]storage.AlertCorrelationRule, 0, len(rules))
+	for _, r := range rules {
+		if r.Enabled {
+			enabled = append(enabled, r)
+		}
+	}
+	return enabled
+}
+
+// correlationGroupName returns the group an alert falls into under rule, or
+// "" when the rule doesn't apply to it.
+func correlationGroupName(rule storage.AlertCorrelationRule, alert *storage.Alert, deviceIPs map[string]string) string {
+	if alert.Type == storage.AlertTypeIncident {
+		return ""
+	}
+	if len(rule.AlertTypes) > 0 && !containsString(rule.AlertTypes, alert.Type) {
+		return ""
+	}
+	switch rule.GroupBy {
+	case storage.CorrelationGroupByAgent:
+		return alert.AgentID
+	case storage.CorrelationGroupByTenant:
+		return alert.TenantID
+	case storage.CorrelationGroupBySubnet:
+		ip := net.ParseIP(deviceIPs[alert.DeviceSerial]).To4()
+		if ip == nil {
+			return ""
+		}
+		bits := rule.SubnetBits
+		if bits <= 0 || bits > 32 {
+			bits = 24
+		}
+		mask := net.CIDRMask(bits, 32)
+		return (&net.IPNet{IP: ip.Mask(mask), Mask: mask}).String()
+	}
+	return ""
+}
+
+// matchCorrelation returns the first rule that applies to alert, its group
+// and correlation key.
+func matchCorrelation(rules []storage.AlertCorrelationRule, alert *storage.Alert, deviceIPs map[string]string) (storage.AlertCorrelationRule, string, string, bool) {
+	for _, rule := range rules {
+		if name := correlationGroupName(rule, alert, deviceIPs); name != "" {
+			return rule, name, rule.Name + "|" + rule.GroupBy + ":" + name, true
+		}
+	}
+	return storage.AlertCorrelationRule{}, "", "", false
+}
+
+// raiseAlerts creates the alerts triggered in one evaluation pass. Alerts
+// matching a correlation rule join the open incident for their group, or
+// open a new incident once the group reaches the rule's MinAlerts (counting
+// ungrouped alerts raised within the window). Past the flood threshold, new
+// top-level alerts are folded into one fleet-wide incident. It returns the
+// incidents that received children.
+func (e *Evaluator) raiseAlerts(ctx context.Context, pending []*storage.Alert, active []storage.Alert, settings *storage.AlertSettings, deviceIPs map[string]string) map[int64]bool {
+	attached := make(map[int64]bool)
+	if len(pending) == 0 {
+		return attached
+	}
+	now := time.Now().UTC()
+	rules := correlationRules(settings)
+	flood := newFloodGate(settings, active, now)
+
+	incidents := make(map[string]int64)
+	for _, a := range active {
+		if a.Type == storage.AlertTypeIncident {
+			if key := parseIncidentDetails(a.Details).CorrelationKey; key != "" {
+				incidents[key] = a.ID
+			}
+		}
+	}
+
+	groups := make(map[string]*correlationGroup)
+	var order []string
+	var singles []*storage.Alert
+	for _, a := range pending {
+		rule, name, key, ok := matchCorrelation(rules, a, deviceIPs)
+		if !ok {
+			singles = append(singles, a)
+			continue
+		}
+		g := groups[key]
+		if g == nil {
+			g = &correlationGroup{rule: rule, name: name, key: key}
+			groups[key] = g
+			order = append(order, key)
+		}
+		g.alerts = append(g.alerts, a)
+	}
+	for i := range active {
+		a := &active[i]
+		if a.ParentAlertID != nil {
+			continue
+		}
+		rule, _, key, ok := matchCorrelation(rules, a, deviceIPs)
+		if !ok || groups[key] == nil {
+			continue
+		}
+		window := time.Duration(rule.WindowMinutes) * time.Minute
+		if window <= 0 || now.Sub(a.TriggeredAt) <= window {
+			groups[key].existing = append(groups[key].existing, a.ID)
+		}
+	}
+
+	// Existing children to move under each incident
+	adopt := make(map[int64][]int64)
+
+	for _, key := range order {
+		g := groups[key]
+		if id, ok := incidents[key]; ok {
+			e.createChildren(ctx, id, g.alerts)
+			attached[id] = true
+			adopt[id] = append(adopt[id], g.existing...)
+			continue
+		}
+		minAlerts := g.rule.MinAlerts
+		if minAlerts < 2 {
+			minAlerts = 2
+		}
+		if len(g.alerts)+len(g.existing) < minAlerts {
+			singles = append(singles, g.alerts...)
+			continue
+		}
+		if !flood.allow() {
+			singles = append(singles, g.alerts...)
+			continue
+		}
+		id, err := e.createIncident(ctx, newGroupIncident(g, now))
+		if err != nil {
+			singles = append(singles, g.alerts...)
+			continue
+		}
+		incidents[key] = id
+		e.createChildren(ctx, id, g.alerts)
+		attached[id] = true
+		adopt[id] = append(adopt[id], g.existing...)
+	}
+
+	for _, a := range singles {
+		if flood.allow() {
+			e.createAlert(ctx, a)
+			continue
+		}
+		if flood.incidentID == 0 {
+			id, err := e.createIncident(ctx, newFloodIncident(settings, now))
+			if err != nil {
+				e.createAlert(ctx, a)
+				continue
+			}
+			flood.incidentID = id
+		}
+		e.createChildren(ctx, flood.incidentID, []*storage.Alert{a})
+		attached[flood.incidentID] = true
+	}
+
+	// Move existing alerts under their incident and refresh child counts
+	for id := range attached {
+		if err := e.store.SetAlertParent(ctx, id, adopt[id]); err != nil {
+			e.logger.Error("failed to attach alerts to incident", "error", err, "incident", id)
+		}
+	}
+	return attached
+}
+
+func (e *Evaluator) createAlert(ctx context.Context, alert *storage.Alert) {
+	id, err := e.store.CreateAlert(ctx, alert)
+	if err != nil {
+		e.logger.Error("failed to create alert", "error", err, "type", alert.Type, "device", alert.DeviceSerial, "agent", alert.AgentID)
+		return
+	}
+	e.logger.Info("alert created", "id", id, "type", alert.Type, "device", alert.DeviceSerial, "agent", alert.AgentID)
+}
+
+func (e *Evaluator) createChildren(ctx context.Context, parentID int64, alerts []*storage.Alert) {
+	for _, a := range alerts {
+		parent := parentID
+		a.ParentAlertID = &parent
+		e.createAlert(ctx, a)
+	}
+}
+
+func (e *Evaluator) createIncident(ctx context.Context, incident *storage.Alert) (int64, error) {
+	id, err := e.store.CreateAlert(ctx, incident)
+	if err != nil {
+		e.logger.Error("failed to create incident", "error", err, "title", incident.Title)
+		return 0, err
+	}
+	e.logger.Info("incident created", "id", id, "title", incident.Title)
+	return id, nil
+}
+
+// newGroupIncident builds the parent alert for a correlation group.
+func newGroupIncident(g *correlationGroup, now time.Time) *storage.Alert {
+	details, _ := json.Marshal(incidentDetails{
+		CorrelationKey: g.key,
+		Rule:           g.rule.Name,
+		GroupBy:        g.rule.GroupBy,
+		Group:          g.name,
+	})
+	incident := &storage.Alert{
+		Type:        storage.AlertTypeIncident,
+		Severity:    storage.AlertSeverityInfo,
+		Scope:       storage.AlertScopeFleet,
+		Status:      storage.AlertStatusActive,
+		Title:       fmt.Sprintf("%s: %s", g.rule.Name, g.name),
+		Message:     fmt.Sprintf("%d related %s alerts in %s %s; individual alerts are grouped under this incident", len(g.alerts)+len(g.existing), alertTypeList(g.alerts), g.rule.GroupBy, g.name),
+		Details:     string(details),
+		TriggeredAt: now,
+	}
+	switch g.rule.GroupBy {
+	case storage.CorrelationGroupByAgent:
+		incident.Scope = storage.AlertScopeAgent
+		incident.AgentID = g.name
+	case storage.CorrelationGroupByTenant:
+		incident.Scope = storage.AlertScopeTenant
+		incident.TenantID = g.name
+	}
+	for _, a := range g.alerts {
+		if severityRank(a.Severity) > severityRank(incident.Severity) {
+			incident.Severity = a.Severity
+		}
+	}
+	return incident
+}
+
+// newFloodIncident builds the fleet-wide incident used during an alert flood.
+func newFloodIncident(settings *storage.AlertSettings, now time.Time) *storage.Alert {
+	details, _ := json.Marshal(incidentDetails{CorrelationKey: floodCorrelationKey, Rule: "flood suppression"})
+	window := settings.FloodWindowMins
+	if window <= 0 {
+		window = 10
+	}
+	return &storage.Alert{
+		Type:        storage.AlertTypeIncident,
+		Severity:    storage.AlertSeverityCritical,
+		Scope:       storage.AlertScopeFleet,
+		Status:      storage.AlertStatusActive,
+		Title:       "Alert flood: new alerts are being grouped",
+		Message:     fmt.Sprintf("More than %d alerts were raised within %d minutes; further alerts are grouped under this incident", settings.FloodThreshold, window),
+		Details:     string(details),
+		TriggeredAt: now,
+	}
+}
+
+func severityRank(severity string) int {
+	switch severity {
+	case storage.AlertSeverityCritical:
+		return 3
+	case storage.AlertSeverityWarning:
+		return 2
+	case storage.AlertSeverityInfo:
+		return 1
+	}
+	return 0
+}
+
+// alertTypeList joins the distinct alert types in alerts.
+func alertTypeList(alerts []*storage.Alert) string {
+	var types []string
+	for _, a := range alerts {
+		if !containsString(types, a.Type) {
+			types = append(types, a.Type)
+		}
+	}
+	return strings.Join(types, "/")
+}
+
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,209 @@
+package alerts
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"printmaster/server/storage"
+)
+
+// offlineDevices returns n devices on agentID with IPs in subnet (e.g.
+// "10.1.2") and no metrics, so a device_offline rule fires for each.
+func offlineDevices(agentID, subnet string, start, n int) []*storage.Device {
+	devices := make([]*storage.Device, 0, n)
+	for i := start; i < start+n; i++ {
+		d := &storage.Device{AgentID: agentID}
+		d.Serial = fmt.Sprintf("%s-%d", agentID, i)
+		d.IP = fmt.Sprintf("%s.%d", subnet, i)
+		devices = append(devices, d)
+	}
+	return devices
+}
+
+func deviceOfflineRule() []storage.AlertRule {
+	return []storage.AlertRule{{
+		ID:       1,
+		Name:     "Device Offline",
+		Enabled:  true,
+		Type:     storage.AlertTypeDeviceOffline,
+		Severity: storage.AlertSeverityWarning,
+		Scope:    storage.AlertScopeDevice,
+	}}
+}
+
+func splitCreated(created []*storage.Alert) (incidents, children, topLevel []*storage.Alert) {
+	for _, a := range created {
+		switch {
+		case a.Type == storage.AlertTypeIncident:
+			incidents = append(incidents, a)
+		case a.ParentAlertID != nil:
+			children = append(children, a)
+		default:
+			topLevel = append(topLevel, a)
+		}
+	}
+	return
+}
+
+func TestCorrelation_SubnetOutageGroupsIntoIncident(t *testing.T) {
+	t.Parallel()
+
+	store := newMockEvaluatorStore()
+	store.settings = &storage.AlertSettings{GroupingEnabled: true}
+	store.devices = append(offlineDevices("agent-1", "10.1.2", 10, 6), offlineDevices("agent-1", "10.9.9", 50, 1)...)
+	store.rules = deviceOfflineRule()
+
+	NewEvaluator(store, EvaluatorConfig{Interval: time.Hour}).evaluate()
+
+	incidents, children, topLevel := splitCreated(store.createdAlerts)
+	if len(incidents) != 1 || len(children) != 6 || len(topLevel) != 1 {
+		t.Fatalf("expected 1 incident, 6 children, 1 alert; got %d, %d, %d", len(incidents), len(children), len(topLevel))
+	}
+	incident := incidents[0]
+	if incident.Title != "Subnet outage: 10.1.2.0/24" {
+		t.Errorf("unexpected incident title %q", incident.Title)
+	}
+	if incident.ChildCount != 6 {
+		t.Errorf("expected child_count 6, got %d", incident.ChildCount)
+	}
+	if parseIncidentDetails(incident.Details).CorrelationKey == "" {
+		t.Error("expected correlation key in incident details")
+	}
+	for _, c := range children {
+		if *c.ParentAlertID != incident.ID {
+			t.Errorf("child %s has parent %d, want %d", c.DeviceSerial, *c.ParentAlertID, incident.ID)
+		}
+	}
+}
+
+func TestCorrelation_BelowThresholdRaisesIndividually(t *testing.T) {
+	t.Parallel()
+
+	store := newMockEvaluatorStore()
+	store.settings = &storage.AlertSettings{GroupingEnabled: true}
+	store.devices = offlineDevices("agent-1", "10.1.2", 10, 3)
+	store.rules = deviceOfflineRule()
+
+	NewEvaluator(store, EvaluatorConfig{Interval: time.Hour}).evaluate()
+
+	incidents, children, topLevel := splitCreated(store.createdAlerts)
+	if len(incidents) != 0 || len(children) != 0 || len(topLevel) != 3 {
+		t.Fatalf("expected 3 individual alerts; got %d incidents, %d children, %d alerts", len(incidents), len(children), len(topLevel))
+	}
+}
+
+func TestCorrelation_RecentAlertsCountAndJoinIncident(t *testing.T) {
+	t.Parallel()
+
+	store := newMockEvaluatorStore()
+	store.settings = &storage.AlertSettings{
+		GroupingEnabled: true,
+		CorrelationRules: []storage.AlertCorrelationRule{{
+			Name: "Agent outage", Enabled: true, GroupBy: storage.CorrelationGroupByAgent,
+			AlertTypes: []string{storage.AlertTypeDeviceOffline}, WindowMinutes: 15, MinAlerts: 4,
+		}},
+	}
+	// Two devices already alerted a few minutes ago, two more go offline now
+	recent := time.Now().UTC().Add(-5 * time.Minute)
+	for i, serial := range []string{"agent-1-1", "agent-1-2"} {
+		store.alerts = append(store.alerts, storage.Alert{
+			ID: int64(100 + i), Type: storage.AlertTypeDeviceOffline, Scope: storage.AlertScopeDevice,
+			Status: storage.AlertStatusActive, AgentID: "agent-1", DeviceSerial: serial, TriggeredAt: recent,
+		})
+	}
+	store.devices = offlineDevices("agent-1", "10.1.2", 1, 4)
+	store.rules = deviceOfflineRule()
+
+	NewEvaluator(store, EvaluatorConfig{Interval: time.Hour}).evaluate()
+
+	incidents, children, _ := splitCreated(store.createdAlerts)
+	if len(incidents) != 1 || len(children) != 2 {
+		t.Fatalf("expected 1 incident with 2 new children; got %d incidents, %d children", len(incidents), len(children))
+	}
+	for _, a := range store.alerts {
+		if a.ParentAlertID == nil || *a.ParentAlertID != incidents[0].ID {
+			t.Errorf("existing alert %d not moved under incident", a.ID)
+		}
+	}
+	if incidents[0].ChildCount != 4 {
+		t.Errorf("expected child_count 4, got %d", incidents[0].ChildCount)
+	}
+}
+
+func TestCorrelation_JoinsOpenIncident(t *testing.T) {
+	t.Parallel()
+
+	store := newMockEvaluatorStore()
+	store.settings = &storage.AlertSettings{GroupingEnabled: true}
+	store.rules = deviceOfflineRule()
+	store.devices = offlineDevices("agent-1", "10.1.2", 1, 2)
+
+	parent := int64(100)
+	store.alerts = []storage.Alert{
+		{ID: 100, Type: storage.AlertTypeIncident, Scope: storage.AlertScopeFleet, Status: storage.AlertStatusActive,
+			Details: `{"correlation_key":"Subnet outage|subnet:10.1.2.0/24"}`},
+		{ID: 101, Type: storage.AlertTypeDeviceOffline, Scope: storage.AlertScopeDevice, Status: storage.AlertStatusActive,
+			AgentID: "agent-1", DeviceSerial: "agent-1-1", ParentAlertID: &parent},
+	}
+
+	NewEvaluator(store, EvaluatorConfig{Interval: time.Hour}).evaluate()
+
+	if len(store.createdAlerts) != 1 {
+		t.Fatalf("expected only the new device alert, got %d alerts", len(store.createdAlerts))
+	}
+	if p := store.createdAlerts[0].ParentAlertID; p == nil || *p != parent {
+		t.Errorf("expected new alert to join incident %d, got parent %v", parent, p)
+	}
+	if store.alerts[0].Status != storage.AlertStatusActive {
+		t.Error("incident with a new child should stay open")
+	}
+}
+
+func TestCorrelation_FloodSuppression(t *testing.T) {
+	t.Parallel()
+
+	store := newMockEvaluatorStore()
+	store.settings = &storage.AlertSettings{FloodSuppressionEnabled: true, FloodThreshold: 2, FloodWindowMins: 10}
+	for i := 0; i < 5; i++ {
+		store.devices = append(store.devices, offlineDevices(fmt.Sprintf("agent-%d", i), fmt.Sprintf("10.%d.0", i), 1, 1)...)
+	}
+	store.rules = deviceOfflineRule()
+
+	NewEvaluator(store, EvaluatorConfig{Interval: time.Hour}).evaluate()
+
+	incidents, children, topLevel := splitCreated(store.createdAlerts)
+	if len(topLevel) != 2 || len(incidents) != 1 || len(children) != 3 {
+		t.Fatalf("expected 2 alerts plus a flood incident with 3 children; got %d, %d, %d", len(topLevel), len(incidents), len(children))
+	}
+	if parseIncidentDetails(incidents[0].Details).CorrelationKey != floodCorrelationKey {
+		t.Errorf("expected flood incident, got details %q", incidents[0].Details)
+	}
+}
+
+func TestCorrelation_IncidentResolvesWithChildren(t *testing.T) {
+	t.Parallel()
+
+	store := newMockEvaluatorStore()
+	store.settings = &storage.AlertSettings{GroupingEnabled: true}
+	store.rules = deviceOfflineRule()
+	store.metrics["agent-1-1"] = &storage.MetricsSnapshot{}
+	store.metrics["agent-1-1"].Timestamp = time.Now()
+	store.devices = offlineDevices("agent-1", "10.1.2", 1, 1)
+
+	parent := int64(1)
+	store.alerts = []storage.Alert{
+		{ID: 1, Type: storage.AlertTypeIncident, Scope: storage.AlertScopeAgent, Status: storage.AlertStatusActive,
+			Details: `{"correlation_key":"Agent outage|agent:agent-1"}`},
+		{ID: 2, Type: storage.AlertTypeDeviceOffline, Scope: storage.AlertScopeDevice, Status: storage.AlertStatusActive,
+			AgentID: "agent-1", DeviceSerial: "agent-1-1", ParentAlertID: &parent},
+	}
+
+	NewEvaluator(store, EvaluatorConfig{Interval: time.Hour}).evaluate()
+
+	for _, a := range store.alerts {
+		if a.Status != storage.AlertStatusResolved {
+			t.Errorf("expected alert %d to be resolved, got %s", a.ID, a.Status)
+		}
+	}
+}
@@ -10,6 +10,7 @@ import (
 	"sync"
 	"time"
 
+	"printmaster/server/devicestatus"
 	"printmaster/server/storage"
 )
 
@@ -20,6 +21,10 @@ type EvaluatorConfig struct {
 
 	// Logger for evaluation events
 	Logger *slog.Logger
+
+	// OnIncident, if set, is called after an incident is promoted (created
+	// true) or has new alerts linked to it.
+	OnIncident func(inc *storage.Incident, created bool)
 }
 
 // EvaluatorStore defines the storage operations needed by the evaluator.
@@ -36,6 +41,14 @@ type EvaluatorStore interface {
 	CreateAlert(ctx context.Context, alert *storage.Alert) (int64, error)
 	ListActiveAlerts(ctx context.Context, filters storage.AlertFilters) ([]storage.Alert, error)
 	ResolveAlert(ctx context.Context, id int64) error
+	SetAlertParent(ctx context.Context, parentID int64, childIDs []int64) error
+	ListAlerts(ctx context.Context, filter storage.AlertFilters) ([]*storage.Alert, error)
+
+	// Incidents
+	ListIncidents(ctx context.Context, filter storage.IncidentFilter) ([]*storage.Incident, error)
+	CreateIncident(ctx context.Context, inc *storage.Incident) error
+	LinkIncidentAlerts(ctx context.Context, incidentID int64, alertIDs []int64) error
+	AddIncidentNote(ctx context.Context, n *storage.IncidentNote) error
 
 	// Maintenance windows
 	GetActiveAlertMaintenanceWindows(ctx context.Context) ([]storage.AlertMaintenanceWindow, error)
@@ -44,6 +57,12 @@ type EvaluatorStore interface {
 	GetAlertSettings(ctx context.Context) (*storage.AlertSettings, error)
 }
 
+// snoozeStore is implemented by stores that can return expired snoozes to
+// the active state.
+type snoozeStore interface {
+	UnsnoozeExpiredAlerts(ctx context.Context, now time.Time) (int64, error)
+}
+
 // Evaluator periodically evaluates alert rules against device/agent state.
 type Evaluator struct {
 	store  EvaluatorStore
@@ -53,6 +72,7 @@ type Evaluator struct {
 	mu       sync.RWMutex
 	running  bool
 	stopChan chan struct{}
+	lastRun  time.Time // Start of the latest evaluation pass
 }
 
 // NewEvaluator creates a new alert evaluator.
@@ -101,6 +121,14 @@ func (e *Evaluator) Stop() {
 	e.logger.Info("alert evaluator stopped")
 }
 
+// LastRun returns when the latest evaluation pass started, or the zero time
+// if none has run yet.
+func (e *Evaluator) LastRun() time.Time {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	return e.lastRun
+}
+
 func (e *Evaluator) runLoop() {
 	ticker := time.NewTicker(e.config.Interval)
 	defer ticker.Stop()
@@ -119,6 +147,10 @@ func (e *Evaluator) runLoop() {
 }
 
 func (e *Evaluator) evaluate() {
+	e.mu.Lock()
+	e.lastRun = time.Now()
+	e.mu.Unlock()
+
 	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 	defer cancel()
 
@@ -151,6 +183,15 @@ func (e *Evaluator) evaluate() {
 		return // No rules to evaluate
 	}
 
+	// Wake snoozed alerts whose snooze has run out
+	if ss, ok := e.store.(snoozeStore); ok {
+		if woken, err := ss.UnsnoozeExpiredAlerts(ctx, time.Now().UTC()); err != nil {
+			e.logger.Error("failed to wake snoozed alerts", "error", err)
+		} else if woken > 0 {
+			e.logger.Info("snoozed alerts returned to active", "count", woken)
+		}
+	}
+
 	// Load existing active alerts to avoid duplicates
 	activeAlerts, err := e.store.ListActiveAlerts(ctx, storage.AlertFilters{})
 	if err != nil {
@@ -162,28 +203,47 @@ func (e *Evaluator) evaluate() {
 		key := alertKey(a.Type, a.Scope, a.DeviceSerial, a.AgentID, a.SiteID, a.TenantID)
 		existingAlertKeys[key] = a
 	}
+	// A snoozed alert still counts as alerting so it is not raised again
+	if snoozed, err := e.store.ListAlerts(ctx, storage.AlertFilters{Status: string(storage.AlertStatusSuppressed)}); err == nil {
+		for _, a := range snoozed {
+			existingAlertKeys[alertKey(a.Type, a.Scope, a.DeviceSerial, a.AgentID, a.SiteID, a.TenantID)] = *a
+		}
+	}
+
+	// Collect triggered alerts, then create them through correlation and
+	// flood suppression so an upstream outage yields one incident
+	var pending []*storage.Alert
+	deviceIPs := make(map[string]string)
 
 	// Evaluate device rules
 	if hasDeviceRules(enabledRules) {
-		e.evaluateDeviceRules(ctx, enabledRules, existingAlertKeys, inMaintenance, inQuietHours)
+		devices, err := e.store.ListAllDevices(ctx)
+		if err != nil {
+			e.logger.Error("failed to list devices", "error", err)
+		} else {
+			for _, d := range devices {
+				deviceIPs[d.Serial] = d.IP
+			}
+			pending = append(pending, e.evaluateDeviceRules(ctx, devices, enabledRules, existingAlertKeys, inMaintenance, inQuietHours)...)
+		}
 	}
 
 	// Evaluate agent rules
 	if hasAgentRules(enabledRules) {
-		e.evaluateAgentRules(ctx, enabledRules, existingAlertKeys, inMaintenance, inQuietHours)
+		pending = append(pending, e.evaluateAgentRules(ctx, enabledRules, existingAlertKeys, inMaintenance, inQuietHours)...)
 	}
 
+	attached := e.raiseAlerts(ctx, pending, activeAlerts, settings, deviceIPs)
+
 	// Auto-resolve cleared alerts
-	e.autoResolveCleared(ctx, activeAlerts)
-}
+	e.autoResolveCleared(ctx, activeAlerts, attached)
 
-func (e *Evaluator) evaluateDeviceRules(ctx context.Context, rules []storage.AlertRule, existing map[string]storage.Alert, inMaint, inQuiet bool) {
-	devices, err := e.store.ListAllDevices(ctx)
-	if err != nil {
-		e.logger.Error("failed to list devices", "error", err)
-		return
-	}
+	// Promote problems that persisted past this pass into incidents
+	e.promoteIncidents(ctx, activeAlerts, settings)
+}
 
+func (e *Evaluator) evaluateDeviceRules(ctx context.Context, devices []*storage.Device, rules []storage.AlertRule, existing map[string]storage.Alert, inMaint, inQuiet bool) []*storage.Alert {
+	var pending []*storage.Alert
 	for _, device := range devices {
 		// Get latest metrics for the device
 		metrics, _ := e.store.GetLatestMetrics(ctx, device.Serial)
@@ -215,8 +275,7 @@ func (e *Evaluator) evaluateDeviceRules(ctx context.Context, rules []storage.Ale
 				continue
 			}
 
-			// Create the alert
-			alert := &storage.Alert{
+			pending = append(pending, &storage.Alert{
 				RuleID:       rule.ID,
 				Type:         rule.Type,
 				Severity:     rule.Severity,
@@ -227,25 +286,20 @@ func (e *Evaluator) evaluateDeviceRules(ctx context.Context, rules []storage.Ale
 				Title:        title,
 				Message:      message,
 				TriggeredAt:  time.Now().UTC(),
-			}
-
-			id, err := e.store.CreateAlert(ctx, alert)
-			if err != nil {
-				e.logger.Error("failed to create alert", "error", err, "rule", rule.Name, "device", device.Serial)
-				continue
-			}
-			e.logger.Info("alert created", "id", id, "type", rule.Type, "device", device.Serial)
+			})
 		}
 	}
+	return pending
 }
 
-func (e *Evaluator) evaluateAgentRules(ctx context.Context, rules []storage.AlertRule, existing map[string]storage.Alert, inMaint, inQuiet bool) {
+func (e *Evaluator) evaluateAgentRules(ctx context.Context, rules []storage.AlertRule, existing map[string]storage.Alert, inMaint, inQuiet bool) []*storage.Alert {
 	agents, err := e.store.ListAgents(ctx)
 	if err != nil {
 		e.logger.Error("failed to list agents", "error", err)
-		return
+		return nil
 	}
 
+	var pending []*storage.Alert
 	for _, agent := range agents {
 		for _, rule := range rules {
 			if rule.Scope != storage.AlertScopeAgent {
@@ -273,7 +327,7 @@ func (e *Evaluator) evaluateAgentRules(ctx context.Context, rules []storage.Aler
 				continue
 			}
 
-			alert := &storage.Alert{
+			pending = append(pending, &storage.Alert{
 				RuleID:      rule.ID,
 				Type:        rule.Type,
 				Severity:    rule.Severity,
@@ -284,19 +338,17 @@ func (e *Evaluator) evaluateAgentRules(ctx context.Context, rules []storage.Aler
 				Title:       title,
 				Message:     message,
 				TriggeredAt: time.Now().UTC(),
-			}
-
-			id, err := e.store.CreateAlert(ctx, alert)
-			if err != nil {
-				e.logger.Error("failed to create alert", "error", err, "rule", rule.Name, "agent", agent.AgentID)
-				continue
-			}
-			e.logger.Info("alert created", "id", id, "type", rule.Type, "agent", agent.AgentID)
+			})
 		}
 	}
+	return pending
 }
 
-func (e *Evaluator) autoResolveCleared(ctx context.Context, activeAlerts []storage.Alert) {
+// autoResolveCleared resolves alerts whose condition no longer holds, then
+// incidents left without open children. Incidents in attached received new
+// children this pass and are kept.
+func (e *Evaluator) autoResolveCleared(ctx context.Context, activeAlerts []storage.Alert, attached map[int64]bool) {
+	resolved := make(map[int64]bool)
 	// For each active alert, check if the condition is still true
 	// If not, auto-resolve it
 	for _, alert := range activeAlerts {
@@ -321,10 +373,28 @@ func (e *Evaluator) autoResolveCleared(ctx context.Context, activeAlerts []stora
 			if err := e.store.ResolveAlert(ctx, alert.ID); err != nil {
 				e.logger.Error("failed to auto-resolve alert", "error", err, "id", alert.ID)
 			} else {
+				resolved[alert.ID] = true
 				e.logger.Info("alert auto-resolved", "id", alert.ID, "type", alert.Type)
 			}
 		}
 	}
+
+	openChildren := make(map[int64]int)
+	for _, alert := range activeAlerts {
+		if alert.ParentAlertID != nil && !resolved[alert.ID] {
+			openChildren[*alert.ParentAlertID]++
+		}
+	}
+	for _, alert := range activeAlerts {
+		if alert.Type != storage.AlertTypeIncident || attached[alert.ID] || openChildren[alert.ID] > 0 {
+			continue
+		}
+		if err := e.store.ResolveAlert(ctx, alert.ID); err != nil {
+			e.logger.Error("failed to auto-resolve incident", "error", err, "id", alert.ID)
+		} else {
+			e.logger.Info("incident auto-resolved", "id", alert.ID, "title", alert.Title)
+		}
+	}
 }
 
 func (e *Evaluator) isDeviceAlertCleared(ctx context.Context, alert storage.Alert) bool {
@@ -361,6 +431,8 @@ func (e *Evaluator) isDeviceAlertCleared(ctx context.Context, alert storage.Aler
 			}
 			return allOK
 		}
+	case storage.AlertTypePaperJam:
+		return !devicestatus.HasJam(device.StatusMessages)
 	case storage.AlertTypeDeviceError:
 		// Device has no explicit Status field in common storage
 		// Check if last seen is recent as proxy for "OK"
@@ -612,17 +684,19 @@ func evaluateDeviceRule(rule storage.AlertRule, device *storage.Device, metrics
 		}
 
 	case storage.AlertTypeDeviceError:
-		// Check status messages for errors
-		if len(device.StatusMessages) > 0 {
-			for _, msg := range device.StatusMessages {
-				// Check for error-like status messages
-				lowerMsg := strings.ToLower(msg)
-				if strings.Contains(lowerMsg, "error") || strings.Contains(lowerMsg, "jam") || strings.Contains(lowerMsg, "fault") {
-					return true,
-						fmt.Sprintf("Device Error: %s", name),
-						fmt.Sprintf("Device %s is reporting: %s", name, msg)
-				}
-			}
+		// Normalize status messages through the shared device status rules
+		status := devicestatus.Active().Classify(device.Manufacturer, device.StatusMessages)
+		if status.State == devicestatus.StateError || status.State == devicestatus.StateOffline {
+			return true,
+				fmt.Sprintf("Device Error: %s", name),
+				fmt.Sprintf("Device %s is reporting: %s", name, status.Message)
+		}
+
+	case storage.AlertTypePaperJam:
+		if devicestatus.HasJam(device.StatusMessages) {
+			return true,
+				fmt.Sprintf("Paper Jam: %s", name),
+				fmt.Sprintf("Device %s is reporting a paper jam: %s", name, strings.Join(device.StatusMessages, "; "))
 		}
 
 	case storage.AlertTypeUsageHigh:
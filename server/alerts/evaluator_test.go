@@ -19,6 +19,10 @@ type mockEvaluatorStore struct {
 	maintenanceWindows []storage.AlertMaintenanceWindow
 	settings           *storage.AlertSettings
 	createdAlerts      []*storage.Alert
+	alertHistory       []*storage.Alert
+	incidents          []*storage.Incident
+	incidentNotes      []*storage.IncidentNote
+	linkedAlerts       map[int64][]int64
 }
 
 func newMockEvaluatorStore() *mockEvaluatorStore {
@@ -67,6 +71,74 @@ func (m *mockEvaluatorStore) ResolveAlert(ctx context.Context, id int64) error {
 	return nil
 }
 
+func (m *mockEvaluatorStore) SetAlertParent(ctx context.Context, parentID int64, childIDs []int64) error {
+	for _, id := range childIDs {
+		for i := range m.alerts {
+			if m.alerts[i].ID == id {
+				parent := parentID
+				m.alerts[i].ParentAlertID = &parent
+			}
+		}
+	}
+	count := 0
+	for _, a := range m.alerts {
+		if a.ParentAlertID != nil && *a.ParentAlertID == parentID {
+			count++
+		}
+	}
+	for _, a := range m.createdAlerts {
+		if a.ParentAlertID != nil && *a.ParentAlertID == parentID {
+			count++
+		}
+	}
+	for _, a := range m.createdAlerts {
+		if a.ID == parentID {
+			a.ChildCount = count
+		}
+	}
+	return nil
+}
+
+func (m *mockEvaluatorStore) ListAlerts(ctx context.Context, filter storage.AlertFilters) ([]*storage.Alert, error) {
+	var out []*storage.Alert
+	for _, a := range m.alertHistory {
+		if (filter.Type == "" || a.Type == filter.Type) && (filter.Status == "" || string(a.Status) == filter.Status) &&
+			(filter.StartTime == nil || !a.TriggeredAt.Before(*filter.StartTime)) {
+			out = append(out, a)
+		}
+	}
+	return out, nil
+}
+
+func (m *mockEvaluatorStore) ListIncidents(ctx context.Context, filter storage.IncidentFilter) ([]*storage.Incident, error) {
+	var out []*storage.Incident
+	for _, inc := range m.incidents {
+		if !filter.OpenOnly || inc.Status != storage.IncidentStatusResolved {
+			out = append(out, inc)
+		}
+	}
+	return out, nil
+}
+
+func (m *mockEvaluatorStore) CreateIncident(ctx context.Context, inc *storage.Incident) error {
+	inc.ID = int64(len(m.incidents) + 1)
+	m.incidents = append(m.incidents, inc)
+	return nil
+}
+
+func (m *mockEvaluatorStore) LinkIncidentAlerts(ctx context.Context, incidentID int64, alertIDs []int64) error {
+	if m.linkedAlerts == nil {
+		m.linkedAlerts = make(map[int64][]int64)
+	}
+	m.linkedAlerts[incidentID] = append(m.linkedAlerts[incidentID], alertIDs...)
+	return nil
+}
+
+func (m *mockEvaluatorStore) AddIncidentNote(ctx context.Context, n *storage.IncidentNote) error {
+	m.incidentNotes = append(m.incidentNotes, n)
+	return nil
+}
+
 func (m *mockEvaluatorStore) GetActiveAlertMaintenanceWindows(ctx context.Context) ([]storage.AlertMaintenanceWindow, error) {
 	return m.maintenanceWindows, nil
 }
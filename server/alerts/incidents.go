@@ -0,0 +1,246 @@
+package alerts
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"printmaster/server/storage"
+)
+
+// incidentAuthor is recorded as the creator of promoted incidents and the
+// author of the history notes the evaluator writes.
+const incidentAuthor = "system"
+
+// incidentPromotion collects the alerts behind one incident to raise or
+// update this pass.
+type incidentPromotion struct {
+	key          string
+	source       string
+	title        string
+	description  string
+	severity     string
+	deviceSerial string
+	agentID      string
+	alertIDs     []int64
+}
+
+// promoteIncidents turns problems that outlast a single alert into
+// incidents: a device offline longer than IncidentOfflineMins, or one that
+// jammed IncidentJamCount times within IncidentJamWindowHours. Offline
+// alerts grouped under a correlated outage become one incident for the
+// outage. An open incident for the same problem gets the new alerts linked
+// instead of a duplicate. Promoted incidents are never resolved here; that
+// is left to whoever works them.
+func (e *Evaluator) promoteIncidents(ctx context.Context, activeAlerts []storage.Alert, settings *storage.AlertSettings) {
+	if settings == nil || !settings.IncidentsEnabled {
+		return
+	}
+	now := time.Now().UTC()
+	offlineAfter := time.Duration(positiveOr(settings.IncidentOfflineMins, 60)) * time.Minute
+	jamCount := positiveOr(settings.IncidentJamCount, 3)
+	jamWindowHours := positiveOr(settings.IncidentJamWindowHours, 24)
+
+	promotions := make(map[string]*incidentPromotion)
+	var order []string
+	add := func(p *incidentPromotion) {
+		if existing, ok := promotions[p.key]; ok {
+			for _, id := range p.alertIDs {
+				if !containsInt64(existing.alertIDs, id) {
+					existing.alertIDs = append(existing.alertIDs, id)
+				}
+			}
+			return
+		}
+		promotions[p.key] = p
+		order = append(order, p.key)
+	}
+
+	alertsByID := make(map[int64]storage.Alert, len(activeAlerts))
+	for _, a := range activeAlerts {
+		alertsByID[a.ID] = a
+	}
+	for _, a := range activeAlerts {
+		if a.Type != storage.AlertTypeDeviceOffline || a.Status == storage.AlertStatusResolved ||
+			now.Sub(a.TriggeredAt) < offlineAfter {
+			continue
+		}
+		if a.ParentAlertID != nil {
+			parent := alertsByID[*a.ParentAlertID]
+			title := parent.Title
+			if title == "" {
+				title = "Multiple devices offline"
+			}
+			add(&incidentPromotion{
+				key:         fmt.Sprintf("%s:alert:%d", storage.IncidentSourceDeviceOffline, *a.ParentAlertID),
+				source:      storage.IncidentSourceDeviceOffline,
+				title:       title,
+				description: parent.Message,
+				severity:    storage.AlertSeverityCritical,
+				agentID:     a.AgentID,
+				alertIDs:    []int64{*a.ParentAlertID, a.ID},
+			})
+			continue
+		}
+		add(&incidentPromotion{
+			key:          storage.IncidentSourceDeviceOffline + ":" + a.DeviceSerial,
+			source:       storage.IncidentSourceDeviceOffline,
+			title:        a.Title,
+			description:  fmt.Sprintf("Offline since %s. %s", a.TriggeredAt.UTC().Format(time.RFC3339), a.Message),
+			severity:     a.Severity,
+			deviceSerial: a.DeviceSerial,
+			agentID:      a.AgentID,
+			alertIDs:     []int64{a.ID},
+		})
+	}
+
+	since := now.Add(-time.Duration(jamWindowHours) * time.Hour)
+	jams, err := e.store.ListAlerts(ctx, storage.AlertFilters{Type: storage.AlertTypePaperJam, StartTime: &since})
+	if err != nil {
+		e.logger.Error("failed to list paper jam alerts", "error", err)
+	}
+	jamsBySerial := make(map[string][]*storage.Alert)
+	for _, a := range jams {
+		if a.DeviceSerial != "" {
+			jamsBySerial[a.DeviceSerial] = append(jamsBySerial[a.DeviceSerial], a)
+		}
+	}
+	serials := make([]string, 0, len(jamsBySerial))
+	for serial := range jamsBySerial {
+		serials = append(serials, serial)
+	}
+	sort.Strings(serials)
+	for _, serial := range serials {
+		deviceJams := jamsBySerial[serial]
+		if len(deviceJams) < jamCount {
+			continue
+		}
+		// ListAlerts returns newest first
+		latest := deviceJams[0]
+		ids := make([]int64, 0, len(deviceJams))
+		for _, a := range deviceJams {
+			ids = append(ids, a.ID)
+		}
+		add(&incidentPromotion{
+			key:          storage.IncidentSourceRepeatedJams + ":" + serial,
+			source:       storage.IncidentSourceRepeatedJams,
+			title:        "Repeated Paper Jams: " + strings.TrimPrefix(latest.Title, "Paper Jam: "),
+			description:  fmt.Sprintf("%d paper jams in the last %d hours. Latest: %s", len(deviceJams), jamWindowHours, latest.Message),
+			severity:     storage.AlertSeverityWarning,
+			deviceSerial: serial,
+			agentID:      latest.AgentID,
+			alertIDs:     ids,
+		})
+	}
+
+	if len(order) == 0 {
+		return
+	}
+
+	open, err := e.store.ListIncidents(ctx, storage.IncidentFilter{OpenOnly: true})
+	if err != nil {
+		e.logger.Error("failed to list open incidents", "error", err)
+		return
+	}
+	openByKey := make(map[string]*storage.Incident)
+	for _, inc := range open {
+		if inc.Key != "" {
+			openByKey[inc.Key] = inc
+		}
+	}
+
+	var agentTenants map[string]string
+	tenantOf := func(agentID string) string {
+		if agentTenants == nil {
+			agentTenants = make(map[string]string)
+			if agents, err := e.store.ListAgents(ctx); err == nil {
+				for _, a := range agents {
+					agentTenants[a.AgentID] = a.TenantID
+				}
+			}
+		}
+		return agentTenants[agentID]
+	}
+
+	for _, key := range order {
+		p := promotions[key]
+		if inc, ok := openByKey[key]; ok {
+			e.linkIncidentAlerts(ctx, inc, p.alertIDs)
+			continue
+		}
+		inc := &storage.Incident{
+			TenantID:     tenantOf(p.agentID),
+			Title:        p.title,
+			Description:  p.description,
+			Severity:     p.severity,
+			Status:       storage.IncidentStatusOpen,
+			DeviceSerial: p.deviceSerial,
+			AgentID:      p.agentID,
+			Source:       p.source,
+			Key:          p.key,
+			CreatedBy:    incidentAuthor,
+			AlertIDs:     p.alertIDs,
+		}
+		if err := e.store.CreateIncident(ctx, inc); err != nil {
+			e.logger.Error("failed to create incident", "error", err, "key", key)
+			continue
+		}
+		e.logger.Info("incident opened", "id", inc.ID, "key", key, "title", inc.Title)
+		if e.config.OnIncident != nil {
+			e.config.OnIncident(inc, true)
+		}
+	}
+}
+
+// linkIncidentAlerts attaches alerts not yet linked to an open incident and
+// notes the change in its history.
+func (e *Evaluator) linkIncidentAlerts(ctx context.Context, inc *storage.Incident, alertIDs []int64) {
+	var fresh []int64
+	for _, id := range alertIDs {
+		if !containsInt64(inc.AlertIDs, id) && !containsInt64(fresh, id) {
+			fresh = append(fresh, id)
+		}
+	}
+	if len(fresh) == 0 {
+		return
+	}
+	if err := e.store.LinkIncidentAlerts(ctx, inc.ID, fresh); err != nil {
+		e.logger.Error("failed to link alerts to incident", "error", err, "incident", inc.ID)
+		return
+	}
+	inc.AlertIDs = append(inc.AlertIDs, fresh...)
+	ids := make([]string, len(fresh))
+	for i, id := range fresh {
+		ids[i] = fmt.Sprintf("#%d", id)
+	}
+	note := &storage.IncidentNote{
+		IncidentID: inc.ID,
+		Kind:       storage.IncidentNoteKindAlerts,
+		Body:       "Linked alerts " + strings.Join(ids, ", "),
+		Author:     incidentAuthor,
+	}
+	if err := e.store.AddIncidentNote(ctx, note); err != nil {
+		e.logger.Error("failed to add incident note", "error", err, "incident", inc.ID)
+	}
+	if e.config.OnIncident != nil {
+		e.config.OnIncident(inc, false)
+	}
+}
+
+func containsInt64(ids []int64, id int64) bool {
+	for _, v := range ids {
+		if v == id {
+			return true
+		}
+	}
+	return false
+}
+
+func positiveOr(v, def int) int {
+	if v > 0 {
+		return v
+	}
+	return def
+}
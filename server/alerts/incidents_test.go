@@ -0,0 +1,101 @@
+package alerts
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"printmaster/server/storage"
+)
+
+func TestPromoteIncidents_OfflineAndRepeatedJams(t *testing.T) {
+	t.Parallel()
+
+	now := time.Now().UTC()
+	store := newMockEvaluatorStore()
+	store.settings = &storage.AlertSettings{IncidentsEnabled: true}
+	store.agents = []*storage.Agent{{AgentID: "agent-1", TenantID: "tenant-a"}}
+	active := []storage.Alert{
+		{ID: 1, Type: storage.AlertTypeDeviceOffline, Severity: storage.AlertSeverityWarning, Status: storage.AlertStatusActive,
+			AgentID: "agent-1", DeviceSerial: "SN1", Title: "Device Offline: Lobby", TriggeredAt: now.Add(-2 * time.Hour)},
+		// Not offline long enough yet
+		{ID: 2, Type: storage.AlertTypeDeviceOffline, Status: storage.AlertStatusActive,
+			AgentID: "agent-1", DeviceSerial: "SN2", Title: "Device Offline: Annex", TriggeredAt: now.Add(-10 * time.Minute)},
+	}
+	for i, serial := range []string{"SN3", "SN3", "SN3", "SN4", "SN4"} {
+		store.alertHistory = append(store.alertHistory, &storage.Alert{
+			ID: int64(10 + i), Type: storage.AlertTypePaperJam, AgentID: "agent-1", DeviceSerial: serial,
+			Title: "Paper Jam: " + serial, TriggeredAt: now.Add(-time.Duration(i+1) * time.Hour),
+		})
+	}
+
+	var opened []*storage.Incident
+	e := NewEvaluator(store, EvaluatorConfig{Interval: time.Hour, OnIncident: func(inc *storage.Incident, created bool) {
+		if created {
+			opened = append(opened, inc)
+		}
+	}})
+	e.promoteIncidents(context.Background(), active, store.settings)
+
+	if len(store.incidents) != 2 || len(opened) != 2 {
+		t.Fatalf("expected 2 incidents, got %d (%d notified)", len(store.incidents), len(opened))
+	}
+	offline, jams := store.incidents[0], store.incidents[1]
+	if offline.Key != "device_offline:SN1" || offline.TenantID != "tenant-a" || offline.Status != storage.IncidentStatusOpen {
+		t.Fatalf("unexpected offline incident: %+v", offline)
+	}
+	if jams.Key != "repeated_jams:SN3" || jams.Title != "Repeated Paper Jams: SN3" || len(jams.AlertIDs) != 3 {
+		t.Fatalf("unexpected jam incident: %+v", jams)
+	}
+
+	// A later pass links new alerts to the open incident instead of opening another
+	store.alertHistory = append(store.alertHistory, &storage.Alert{
+		ID: 20, Type: storage.AlertTypePaperJam, AgentID: "agent-1", DeviceSerial: "SN3",
+		Title: "Paper Jam: SN3", TriggeredAt: now,
+	})
+	store.alertHistory[0], store.alertHistory[len(store.alertHistory)-1] = store.alertHistory[len(store.alertHistory)-1], store.alertHistory[0]
+	e.promoteIncidents(context.Background(), active, store.settings)
+
+	if len(store.incidents) != 2 {
+		t.Fatalf("expected no new incidents, got %d", len(store.incidents))
+	}
+	if got := store.linkedAlerts[jams.ID]; len(got) != 1 || got[0] != 20 {
+		t.Fatalf("expected alert 20 linked, got %v", got)
+	}
+	if len(store.incidentNotes) != 1 || store.incidentNotes[0].Kind != storage.IncidentNoteKindAlerts {
+		t.Fatalf("expected one alerts_linked note, got %+v", store.incidentNotes)
+	}
+}
+
+func TestPromoteIncidents_CorrelatedOutageIsOneIncident(t *testing.T) {
+	t.Parallel()
+
+	now := time.Now().UTC()
+	store := newMockEvaluatorStore()
+	store.settings = &storage.AlertSettings{IncidentsEnabled: true, IncidentOfflineMins: 30}
+	parent := int64(100)
+	active := []storage.Alert{{ID: parent, Type: storage.AlertTypeIncident, Status: storage.AlertStatusActive, Title: "Subnet outage: 10.1.2.0/24"}}
+	for i := int64(1); i <= 5; i++ {
+		active = append(active, storage.Alert{
+			ID: i, Type: storage.AlertTypeDeviceOffline, Status: storage.AlertStatusActive, ParentAlertID: &parent,
+			DeviceSerial: "SN" + string(rune('0'+i)), TriggeredAt: now.Add(-time.Hour),
+		})
+	}
+
+	NewEvaluator(store, EvaluatorConfig{Interval: time.Hour}).promoteIncidents(context.Background(), active, store.settings)
+
+	if len(store.incidents) != 1 {
+		t.Fatalf("expected 1 incident, got %d", len(store.incidents))
+	}
+	inc := store.incidents[0]
+	if inc.Title != "Subnet outage: 10.1.2.0/24" || inc.Severity != storage.AlertSeverityCritical || len(inc.AlertIDs) != 6 {
+		t.Fatalf("unexpected incident: %+v", inc)
+	}
+
+	// Disabled promotion raises nothing
+	store = newMockEvaluatorStore()
+	NewEvaluator(store, EvaluatorConfig{Interval: time.Hour}).promoteIncidents(context.Background(), active, store.settings)
+	if len(store.incidents) != 0 {
+		t.Fatalf("expected no incidents with promotion disabled, got %d", len(store.incidents))
+	}
+}
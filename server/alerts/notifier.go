@@ -170,6 +170,23 @@ type NotifierConfig struct {
 
 	// SMTP configuration for email notifications (used as fallback when channel doesn't specify)
 	SMTP SMTPConfig
+
+	// BaseURL is the server's public URL, used for links in notification templates
+	BaseURL string
+
+	// ActionLinkKey signs the acknowledge/snooze links in alert emails.
+	// Without it (or BaseURL) no action links are generated.
+	ActionLinkKey []byte
+
+	// ActionLinkTTL is how long action links stay valid (default 7 days).
+	ActionLinkTTL time.Duration
+
+	// SnoozeDuration is how long the snooze link silences an alert (default 4h).
+	SnoozeDuration time.Duration
+
+	// ReplyTo, when set, is used as the Reply-To of alert emails and their
+	// subjects carry a signed tag so a reply acknowledges the alert.
+	ReplyTo string
 }
 
 // NotifierStore defines the storage operations needed by the notifier.
@@ -215,6 +232,19 @@ func NewNotifier(store NotifierStore, config NotifierConfig) *Notifier {
 	if config.RetryDelay == 0 {
 		config.RetryDelay = 5 * time.Second
 	}
+	if config.ActionLinkTTL == 0 {
+		config.ActionLinkTTL = DefaultActionLinkTTL
+	}
+	if config.SnoozeDuration == 0 {
+		config.SnoozeDuration = DefaultSnoozeDuration
+	}
+	if config.ReplyTo != "" {
+		replyTo, err := validateAndSanitizeEmail(config.ReplyTo)
+		if err != nil {
+			logger.Warn("ignoring invalid alert reply address", "address", config.ReplyTo, "error", err)
+		}
+		config.ReplyTo = replyTo
+	}
 
 	return &Notifier{
 		store:        store,
@@ -328,7 +358,29 @@ func (n *Notifier) TestChannel(ctx context.Context, channel *storage.Notificatio
 	return n.dispatchToChannel(ctx, channel, alert)
 }
 
+// TestTemplate renders tmpl against data and sends the result to an email
+// or webhook channel, so unsaved template edits can be tried out.
+func (n *Notifier) TestTemplate(ctx context.Context, channel *storage.NotificationChannel, tmpl *storage.NotificationTemplate, data TemplateData) error {
+	if tmpl == nil {
+		return fmt.Errorf("no template")
+	}
+	if tmpl.ChannelType != channel.Type {
+		return fmt.Errorf("template is for %s channels, not %s", tmpl.ChannelType, channel.Type)
+	}
+	msg, err := RenderNotificationTemplate(tmpl, data)
+	if err != nil {
+		return err
+	}
+	return n.dispatch(ctx, channel, nil, msg)
+}
+
 func (n *Notifier) dispatchToChannel(ctx context.Context, channel *storage.NotificationChannel, alert *storage.Alert) error {
+	return n.dispatch(ctx, channel, alert, nil)
+}
+
+// dispatch sends alert to channel. Email and webhook channels send msg when
+// it is set instead of rendering the alert with the applicable template.
+func (n *Notifier) dispatch(ctx context.Context, channel *storage.NotificationChannel, alert *storage.Alert, msg *RenderedNotification) error {
 	// Parse config from JSON
 	var config map[string]interface{}
 	if channel.ConfigJSON != "" {
@@ -338,10 +390,17 @@ func (n *Notifier) dispatchToChannel(ctx context.Context, channel *storage.Notif
 	}
 
 	switch channel.Type {
-	case storage.ChannelTypeEmail:
-		return n.sendEmail(ctx, config, alert)
-	case storage.ChannelTypeWebhook:
-		return n.sendWebhook(ctx, config, alert)
+	case storage.ChannelTypeEmail, storage.ChannelTypeWebhook:
+		if msg == nil {
+			var err error
+			if msg, err = n.renderForChannel(ctx, channel.Type, alert); err != nil {
+				return fmt.Errorf("failed to render %s notification: %w", channel.Type, err)
+			}
+		}
+		if channel.Type == storage.ChannelTypeEmail {
+			return n.sendEmail(ctx, config, msg)
+		}
+		return n.sendWebhook(ctx, config, msg)
 	case storage.ChannelTypeSlack:
 		return n.sendSlack(ctx, config, alert)
 	case storage.ChannelTypeTeams:
@@ -362,7 +421,7 @@ func (n *Notifier) dispatchToChannel(ctx context.Context, channel *storage.Notif
 }
 
 // Email notification
-func (n *Notifier) sendEmail(ctx context.Context, config map[string]interface{}, alert *storage.Alert) error {
+func (n *Notifier) sendEmail(ctx context.Context, config map[string]interface{}, rendered *RenderedNotification) error {
 	if config == nil {
 		config = make(map[string]interface{})
 	}
@@ -431,21 +490,8 @@ func (n *Notifier) sendEmail(ctx context.Context, config map[string]interface{},
 		return fmt.Errorf("no valid recipient addresses")
 	}
 
-	// Build message with sanitized headers to prevent header injection
-	subject := sanitizeEmailHeader(fmt.Sprintf("[%s] %s", strings.ToUpper(string(alert.Severity)), alert.Title))
-	body := fmt.Sprintf(`Alert: %s
-Severity: %s
-Scope: %s
-Time: %s
-
-%s
-
----
-This is an automated alert from PrintMaster.
-`, alert.Title, alert.Severity, alert.Scope, alert.TriggeredAt.Format(time.RFC3339), alert.Message)
-
-	msg := []byte(fmt.Sprintf("From: %s\r\nTo: %s\r\nSubject: %s\r\nContent-Type: text/plain; charset=UTF-8\r\n\r\n%s",
-		sanitizedFrom, strings.Join(sanitizedTo, ", "), subject, body))
+	// Subject was sanitized when rendered to prevent header injection
+	msg := buildEmailMessage(sanitizedFrom, sanitizedTo, rendered)
 
 	addr := fmt.Sprintf("%s:%d", host, port)
 
@@ -468,7 +514,29 @@ This is an automated alert from PrintMaster.
 
 	return fmt.Errorf("email send failed after %d attempts: %w", n.config.MaxRetries, lastErr)
 }
-func (n *Notifier) sendWebhook(ctx context.Context, config map[string]interface{}, alert *storage.Alert) error {
+
+// buildEmailMessage assembles the MIME message. With an HTML body it is sent
+// as multipart/alternative so clients without HTML support show the text.
+func buildEmailMessage(from string, to []string, rendered *RenderedNotification) []byte {
+	var b strings.Builder
+	fmt.Fprintf(&b, "From: %s\r\nTo: %s\r\n", from, strings.Join(to, ", "))
+	if rendered.ReplyTo != "" {
+		fmt.Fprintf(&b, "Reply-To: %s\r\n", rendered.ReplyTo)
+	}
+	fmt.Fprintf(&b, "Subject: %s\r\nMIME-Version: 1.0\r\n", rendered.Subject)
+	if rendered.HTML == "" {
+		fmt.Fprintf(&b, "Content-Type: text/plain; charset=UTF-8\r\n\r\n%s", rendered.Text)
+		return []byte(b.String())
+	}
+	boundary := fmt.Sprintf("printmaster-%d", time.Now().UnixNano())
+	fmt.Fprintf(&b, "Content-Type: multipart/alternative; boundary=%q\r\n\r\n", boundary)
+	fmt.Fprintf(&b, "--%s\r\nContent-Type: text/plain; charset=UTF-8\r\n\r\n%s\r\n", boundary, rendered.Text)
+	fmt.Fprintf(&b, "--%s\r\nContent-Type: text/html; charset=UTF-8\r\n\r\n%s\r\n", boundary, rendered.HTML)
+	fmt.Fprintf(&b, "--%s--\r\n", boundary)
+	return []byte(b.String())
+}
+
+func (n *Notifier) sendWebhook(ctx context.Context, config map[string]interface{}, rendered *RenderedNotification) error {
 	if config == nil {
 		return fmt.Errorf("webhook channel has no configuration")
 	}
@@ -488,23 +556,8 @@ func (n *Notifier) sendWebhook(ctx context.Context, config map[string]interface{
 		}
 	}
 
-	// Build payload
-	payload := map[string]interface{}{
-		"alert_id":      alert.ID,
-		"type":          alert.Type,
-		"severity":      alert.Severity,
-		"scope":         alert.Scope,
-		"status":        alert.Status,
-		"title":         alert.Title,
-		"message":       alert.Message,
-		"triggered_at":  alert.TriggeredAt.Format(time.RFC3339),
-		"device_serial": alert.DeviceSerial,
-		"agent_id":      alert.AgentID,
-		"site_id":       alert.SiteID,
-		"tenant_id":     alert.TenantID,
-	}
-
-	return n.postJSON(ctx, url, headers, payload)
+	// The rendered body is validated as JSON and posted unchanged
+	return n.postJSON(ctx, url, headers, json.RawMessage(rendered.Text))
 }
 
 // Slack notification
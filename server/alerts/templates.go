@@ -0,0 +1,434 @@
+package alerts
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	htmltemplate "html/template"
+	"strings"
+	texttemplate "text/template"
+	"time"
+
+	"printmaster/server/storage"
+)
+
+// NotificationTemplateStore is implemented by stores that keep customized
+// notification templates. Without it the built-in templates are used.
+type NotificationTemplateStore interface {
+	ResolveNotificationTemplate(ctx context.Context, tenantID, channelType string) (*storage.NotificationTemplate, error)
+}
+
+// templateContextStore looks up the device, agent, tenant and metrics an
+// alert refers to so templates can include them.
+type templateContextStore interface {
+	GetDevice(ctx context.Context, serial string) (*storage.Device, error)
+	GetAgent(ctx context.Context, agentID string) (*storage.Agent, error)
+	GetTenant(ctx context.Context, id string) (*storage.Tenant, error)
+	GetLatestMetrics(ctx context.Context, serial string) (*storage.MetricsSnapshot, error)
+}
+
+// TemplateData is the value notification templates are executed against.
+type TemplateData struct {
+	Alert   TemplateAlert
+	Rule    TemplateRule
+	Device  TemplateDevice
+	Metrics TemplateMetrics
+	Tenant  TemplateTenant
+	Agent   TemplateAgent
+	Links   TemplateLinks
+}
+
+// TemplateAlert holds the alert fields available to templates.
+type TemplateAlert struct {
+	ID           int64
+	Type         string
+	Severity     string
+	Scope        string
+	Status       string
+	Title        string
+	Message      string
+	TriggeredAt  time.Time
+	DeviceSerial string
+	AgentID      string
+	SiteID       string
+	TenantID     string
+}
+
+// TemplateRule describes the rule that raised the alert.
+type TemplateRule struct {
+	ID            int64
+	Name          string
+	Threshold     float64
+	ThresholdUnit string
+}
+
+// TemplateDevice describes the alerting device, if any.
+type TemplateDevice struct {
+	Serial       string
+	IP           string
+	Manufacturer string
+	Model        string
+	Hostname     string
+	Location     string
+	AssetNumber  string
+}
+
+// TemplateMetrics holds the device's latest metrics.
+type TemplateMetrics struct {
+	PageCount   int
+	ColorPages  int
+	MonoPages   int
+	TonerLevels map[string]interface{}
+	CollectedAt time.Time
+}
+
+// TemplateTenant describes the tenant the alert belongs to.
+type TemplateTenant struct {
+	ID   string
+	Name string
+}
+
+// TemplateAgent describes the agent that reported the alert.
+type TemplateAgent struct {
+	ID       string
+	Name     string
+	Hostname string
+}
+
+// TemplateLinks are absolute links into the web UI, built from the server's
+// public URL. They are empty when no public URL is configured.
+type TemplateLinks struct {
+	Dashboard string
+	Alerts    string
+	Devices   string
+	// Acknowledge and Snooze are signed one-click links for this alert. They
+	// are also empty when no action link key is configured.
+	Acknowledge string
+	Snooze      string
+}
+
+// TemplateVariable documents a variable for the template editor.
+type TemplateVariable struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+}
+
+// TemplateVariables lists the variables and functions templates may use.
+var TemplateVariables = []TemplateVariable{
+	{"{{.Alert.Title}}", "Alert title"},
+	{"{{.Alert.Message}}", "Alert message"},
+	{"{{.Alert.Severity}}", "critical, warning or info"},
+	{"{{.Alert.Type}}", "Alert type, e.g. supply_low"},
+	{"{{.Alert.Scope}}", "device, agent, site, tenant or fleet"},
+	{"{{.Alert.Status}}", "active, acknowledged, resolved, ..."},
+	{"{{.Alert.TriggeredAt}}", "When the alert fired (use rfc3339 to format)"},
+	{"{{.Alert.ID}}", "Alert ID"},
+	{"{{.Rule.Name}}", "Alert rule name"},
+	{"{{.Rule.Threshold}} {{.Rule.ThresholdUnit}}", "Rule threshold"},
+	{"{{.Device.Serial}}", "Device serial number"},
+	{"{{.Device.Manufacturer}} {{.Device.Model}}", "Device make and model"},
+	{"{{.Device.IP}}", "Device IP address"},
+	{"{{.Device.Hostname}}", "Device hostname"},
+	{"{{.Device.Location}}", "Device location"},
+	{"{{.Device.AssetNumber}}", "Device asset number"},
+	{"{{.Metrics.PageCount}}", "Latest total page count"},
+	{"{{.Metrics.TonerLevels}}", "Latest toner levels by color"},
+	{"{{.Tenant.Name}}", "Tenant name"},
+	{"{{.Agent.Name}}", "Reporting agent name"},
+	{"{{.Links.Dashboard}}", "Link to the dashboard"},
+	{"{{.Links.Alerts}}", "Link to the alerts page"},
+	{"{{.Links.Devices}}", "Link to the devices page"},
+	{"{{.Links.Acknowledge}}", "One-click link that acknowledges the alert without logging in"},
+	{"{{.Links.Snooze}}", "One-click link that snoozes the alert without logging in"},
+	{"{{upper .Alert.Severity}}", "Functions: upper, lower, rfc3339, json (webhook bodies)"},
+}
+
+var templateFuncs = map[string]interface{}{
+	"upper": strings.ToUpper,
+	"lower": strings.ToLower,
+	"rfc3339": func(t time.Time) string {
+		if t.IsZero() {
+			return ""
+		}
+		return t.UTC().Format(time.RFC3339)
+	},
+	"json": func(v interface{}) (string, error) {
+		data, err := json.Marshal(v)
+		return string(data), err
+	},
+}
+
+// Built-in templates, used when no stored template applies.
+const (
+	defaultEmailSubject = `[{{upper .Alert.Severity}}] {{.Alert.Title}}`
+	defaultEmailText    = `Alert: {{.Alert.Title}}
+Severity: {{.Alert.Severity}}
+Scope: {{.Alert.Scope}}
+Time: {{rfc3339 .Alert.TriggeredAt}}
+{{- if .Device.Serial}}
+Device: {{.Device.Manufacturer}} {{.Device.Model}} ({{.Device.Serial}}){{end}}
+{{- if .Tenant.Name}}
+Tenant: {{.Tenant.Name}}{{end}}
+
+{{.Alert.Message}}
+{{- if .Links.Acknowledge}}
+
+Acknowledge: {{.Links.Acknowledge}}
+Snooze: {{.Links.Snooze}}{{end}}
+{{- if .Links.Alerts}}
+
+View alerts: {{.Links.Alerts}}{{end}}
+
+---
+This is an automated alert from PrintMaster.
+`
+	defaultWebhookBody = `{
+  "alert_id": {{json .Alert.ID}},
+  "type": {{json .Alert.Type}},
+  "severity": {{json .Alert.Severity}},
+  "scope": {{json .Alert.Scope}},
+  "status": {{json .Alert.Status}},
+  "title": {{json .Alert.Title}},
+  "message": {{json .Alert.Message}},
+  "triggered_at": {{json (rfc3339 .Alert.TriggeredAt)}},
+  "device_serial": {{json .Alert.DeviceSerial}},
+  "agent_id": {{json .Alert.AgentID}},
+  "site_id": {{json .Alert.SiteID}},
+  "tenant_id": {{json .Alert.TenantID}}
+}
+`
+)
+
+// DefaultNotificationTemplate returns the built-in template for a channel
+// type, or nil if the type cannot be templated.
+func DefaultNotificationTemplate(channelType string) *storage.NotificationTemplate {
+	switch channelType {
+	case storage.ChannelTypeEmail:
+		return &storage.NotificationTemplate{ChannelType: storage.ChannelTypeEmail, Subject: defaultEmailSubject, TextBody: defaultEmailText}
+	case storage.ChannelTypeWebhook:
+		return &storage.NotificationTemplate{ChannelType: storage.ChannelTypeWebhook, TextBody: defaultWebhookBody}
+	}
+	return nil
+}
+
+// RenderedNotification is a template rendered for one alert. Email uses
+// Subject, Text and optionally HTML; webhooks post Text as the JSON body.
+type RenderedNotification struct {
+	Subject string `json:"subject,omitempty"`
+	Text    string `json:"text"`
+	HTML    string `json:"html,omitempty"`
+	// ReplyTo is set on alert emails when reply acknowledgement is enabled.
+	ReplyTo string `json:"-"`
+}
+
+// RenderNotificationTemplate executes a template against data. Webhook bodies
+// must render to valid JSON.
+func RenderNotificationTemplate(t *storage.NotificationTemplate, data TemplateData) (*RenderedNotification, error) {
+	if t == nil {
+		return nil, fmt.Errorf("no template")
+	}
+	var out RenderedNotification
+	var err error
+	if t.Subject != "" {
+		if out.Subject, err = renderText("subject", t.Subject, data); err != nil {
+			return nil, err
+		}
+		out.Subject = sanitizeEmailHeader(strings.TrimSpace(out.Subject))
+	}
+	if out.Text, err = renderText("text_body", t.TextBody, data); err != nil {
+		return nil, err
+	}
+	if t.HTMLBody != "" {
+		tmpl, err := htmltemplate.New("html_body").Funcs(templateFuncs).Parse(t.HTMLBody)
+		if err != nil {
+			return nil, fmt.Errorf("html_body: %w", err)
+		}
+		var buf bytes.Buffer
+		if err := tmpl.Execute(&buf, data); err != nil {
+			return nil, fmt.Errorf("html_body: %w", err)
+		}
+		out.HTML = buf.String()
+	}
+	if t.ChannelType == storage.ChannelTypeWebhook && !json.Valid([]byte(out.Text)) {
+		return nil, fmt.Errorf("text_body: webhook template did not render valid JSON (use the json function to quote values)")
+	}
+	return &out, nil
+}
+
+func renderText(name, text string, data TemplateData) (string, error) {
+	tmpl, err := texttemplate.New(name).Funcs(templateFuncs).Parse(text)
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", name, err)
+	}
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return "", fmt.Errorf("%s: %w", name, err)
+	}
+	return buf.String(), nil
+}
+
+// SampleTemplateData returns representative data for previews and test sends.
+func SampleTemplateData(baseURL string) TemplateData {
+	return TemplateData{
+		Alert: TemplateAlert{
+			ID:           12345,
+			Type:         storage.AlertTypeSupplyLow,
+			Severity:     storage.AlertSeverityWarning,
+			Scope:        storage.AlertScopeDevice,
+			Status:       storage.AlertStatusActive,
+			Title:        "Toner low on Front Office Printer",
+			Message:      "Black toner is at 8% (threshold 10%).",
+			TriggeredAt:  time.Now().UTC().Truncate(time.Second),
+			DeviceSerial: "CNB1234567",
+			AgentID:      "sample-agent",
+			TenantID:     "sample-tenant",
+		},
+		Rule:   TemplateRule{ID: 1, Name: "Low toner", Threshold: 10, ThresholdUnit: "%"},
+		Device: TemplateDevice{Serial: "CNB1234567", IP: "192.168.1.50", Manufacturer: "HP", Model: "LaserJet Pro M404", Hostname: "front-office-printer", Location: "Front office", AssetNumber: "AST-0042"},
+		Metrics: TemplateMetrics{
+			PageCount:   48213,
+			MonoPages:   48213,
+			TonerLevels: map[string]interface{}{"black": 8},
+			CollectedAt: time.Now().UTC().Truncate(time.Second),
+		},
+		Tenant: TemplateTenant{ID: "sample-tenant", Name: "Acme Corp"},
+		Agent:  TemplateAgent{ID: "sample-agent", Name: "Acme HQ", Hostname: "acme-hq-01"},
+		Links:  templateLinks(baseURL),
+	}
+}
+
+func templateLinks(baseURL string) TemplateLinks {
+	base := strings.TrimRight(strings.TrimSpace(baseURL), "/")
+	if base == "" {
+		return TemplateLinks{}
+	}
+	return TemplateLinks{
+		Dashboard: base + "/#dashboard",
+		Alerts:    base + "/#alerts",
+		Devices:   base + "/#devices",
+	}
+}
+
+// SampleTemplateData returns sample data carrying this server's links.
+func (n *Notifier) SampleTemplateData() TemplateData {
+	return SampleTemplateData(n.config.BaseURL)
+}
+
+// TemplateData gathers what templates may reference about an alert. Lookups
+// are best effort; missing entities leave their fields empty.
+func (n *Notifier) TemplateData(ctx context.Context, alert *storage.Alert) TemplateData {
+	data := TemplateData{
+		Alert: TemplateAlert{
+			ID:           alert.ID,
+			Type:         alert.Type,
+			Severity:     alert.Severity,
+			Scope:        alert.Scope,
+			Status:       alert.Status,
+			Title:        alert.Title,
+			Message:      alert.Message,
+			TriggeredAt:  alert.TriggeredAt,
+			DeviceSerial: alert.DeviceSerial,
+			AgentID:      alert.AgentID,
+			SiteID:       alert.SiteID,
+			TenantID:     alert.TenantID,
+		},
+		Links: templateLinks(n.config.BaseURL),
+	}
+	data.Links.Acknowledge, data.Links.Snooze = n.actionLinks(alert.ID)
+	if alert.RuleID != 0 {
+		if rule, err := n.store.GetAlertRule(ctx, alert.RuleID); err == nil && rule != nil {
+			data.Rule = TemplateRule{ID: rule.ID, Name: rule.Name, Threshold: rule.Threshold, ThresholdUnit: rule.ThresholdUnit}
+		}
+	}
+
+	cs, ok := n.store.(templateContextStore)
+	if !ok {
+		data.Tenant.ID = alert.TenantID
+		return data
+	}
+	agentID := alert.AgentID
+	if alert.DeviceSerial != "" {
+		if dev, err := cs.GetDevice(ctx, alert.DeviceSerial); err == nil && dev != nil {
+			data.Device = TemplateDevice{
+				Serial:       dev.Serial,
+				IP:           dev.IP,
+				Manufacturer: dev.Manufacturer,
+				Model:        dev.Model,
+				Hostname:     dev.Hostname,
+				Location:     dev.Location,
+				AssetNumber:  dev.AssetNumber,
+			}
+			if agentID == "" {
+				agentID = dev.AgentID
+			}
+		} else {
+			data.Device.Serial = alert.DeviceSerial
+		}
+		if m, err := cs.GetLatestMetrics(ctx, alert.DeviceSerial); err == nil && m != nil {
+			data.Metrics = TemplateMetrics{
+				PageCount:   m.PageCount,
+				ColorPages:  m.ColorPages,
+				MonoPages:   m.MonoPages,
+				TonerLevels: m.TonerLevels,
+				CollectedAt: m.Timestamp,
+			}
+		}
+	}
+	tenantID := alert.TenantID
+	if agentID != "" {
+		if agent, err := cs.GetAgent(ctx, agentID); err == nil && agent != nil {
+			data.Agent = TemplateAgent{ID: agent.AgentID, Name: agent.Name, Hostname: agent.Hostname}
+			if tenantID == "" {
+				tenantID = agent.TenantID
+			}
+		}
+	}
+	data.Tenant.ID = tenantID
+	if tenantID != "" {
+		if tenant, err := cs.GetTenant(ctx, tenantID); err == nil && tenant != nil {
+			data.Tenant.Name = tenant.Name
+		}
+	}
+	return data
+}
+
+// renderForChannel renders the notification for an alert with the tenant's
+// template, else the global template, else the built-in one. A stored
+// template that fails to render falls back to the built-in one so a bad edit
+// cannot silence alerts.
+func (n *Notifier) renderForChannel(ctx context.Context, channelType string, alert *storage.Alert) (*RenderedNotification, error) {
+	rendered, err := n.renderTemplateForChannel(ctx, channelType, alert)
+	if err == nil && channelType == storage.ChannelTypeEmail {
+		n.addReplyTag(rendered, alert)
+	}
+	return rendered, err
+}
+
+// addReplyTag makes an alert email answerable: replies go to the configured
+// reply address and the subject tag identifies the alert they acknowledge.
+func (n *Notifier) addReplyTag(rendered *RenderedNotification, alert *storage.Alert) {
+	if n.config.ReplyTo == "" || len(n.config.ActionLinkKey) == 0 || alert == nil || alert.ID == 0 {
+		return
+	}
+	rendered.ReplyTo = n.config.ReplyTo
+	rendered.Subject = sanitizeEmailHeader(rendered.Subject + " " + AlertReplyTag(n.config.ActionLinkKey, alert.ID))
+}
+
+func (n *Notifier) renderTemplateForChannel(ctx context.Context, channelType string, alert *storage.Alert) (*RenderedNotification, error) {
+	data := n.TemplateData(ctx, alert)
+	if ts, ok := n.store.(NotificationTemplateStore); ok {
+		stored, err := ts.ResolveNotificationTemplate(ctx, data.Tenant.ID, channelType)
+		if err != nil {
+			n.logger.Warn("failed to load notification template", "type", channelType, "tenant_id", data.Tenant.ID, "error", err)
+		} else if stored != nil {
+			rendered, err := RenderNotificationTemplate(stored, data)
+			if err == nil {
+				return rendered, nil
+			}
+			n.logger.Warn("notification template failed to render, using built-in template",
+				"template_id", stored.ID, "type", channelType, "error", err)
+		}
+	}
+	return RenderNotificationTemplate(DefaultNotificationTemplate(channelType), data)
+}
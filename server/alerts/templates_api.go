@@ -0,0 +1,253 @@
+package alerts
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	authz "printmaster/server/authz"
+	"printmaster/server/storage"
+)
+
+// ============================================================================
+// Notification Template Handlers
+// ============================================================================
+
+func (api *API) handleNotificationTemplates(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		api.handleListNotificationTemplates(w, r)
+	case http.MethodPost, http.MethodPut:
+		api.handleSaveNotificationTemplate(w, r)
+	default:
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+	}
+}
+
+// handleListNotificationTemplates returns the stored templates along with the
+// built-in defaults and the variables the editor offers.
+func (api *API) handleListNotificationTemplates(w http.ResponseWriter, r *http.Request) {
+	if !api.authorize(w, r, authz.ActionSettingsAlertsRead, authz.ResourceRef{}) {
+		return
+	}
+
+	templates, err := api.store.ListNotificationTemplates(r.Context())
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to list notification templates")
+		return
+	}
+	if templates == nil {
+		templates = []*storage.NotificationTemplate{}
+	}
+	defaults := make(map[string]*storage.NotificationTemplate, len(storage.NotificationTemplateChannelTypes))
+	for _, ct := range storage.NotificationTemplateChannelTypes {
+		defaults[ct] = DefaultNotificationTemplate(ct)
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"templates": templates,
+		"count":     len(templates),
+		"defaults":  defaults,
+		"variables": TemplateVariables,
+	})
+}
+
+// handleSaveNotificationTemplate creates or replaces the template for a
+// tenant (or the global default) and channel type. Templates that fail to
+// render against sample data are rejected.
+func (api *API) handleSaveNotificationTemplate(w http.ResponseWriter, r *http.Request) {
+	var tmpl storage.NotificationTemplate
+	if err := decodeJSON(r.Body, &tmpl); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if !api.authorize(w, r, authz.ActionSettingsAlertsWrite, templateResource(&tmpl)) {
+		return
+	}
+	if err := tmpl.Validate(); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if _, err := RenderNotificationTemplate(&tmpl, api.sampleTemplateData()); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	tmpl.UpdatedBy = api.actorLabel(r)
+	if err := api.store.UpsertNotificationTemplate(r.Context(), &tmpl); err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to save notification template")
+		return
+	}
+
+	api.audit(r, &storage.AuditEntry{
+		Action:     "notification_template.save",
+		TargetType: "notification_template",
+		TargetID:   fmt.Sprintf("%d", tmpl.ID),
+		TenantID:   tmpl.TenantID,
+		Details:    fmt.Sprintf("Saved %s notification template for %s (%s)", tmpl.ChannelType, templateScopeLabel(&tmpl), api.actorLabel(r)),
+	})
+
+	writeJSON(w, http.StatusOK, &tmpl)
+}
+
+func (api *API) handleNotificationTemplateRoute(w http.ResponseWriter, r *http.Request) {
+	idStr := strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/v1/notification-templates/"), "/")
+	id, err := strconv.ParseInt(idStr, 10, 64)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid template id")
+		return
+	}
+
+	tmpl, err := api.store.GetNotificationTemplate(r.Context(), id)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to load notification template")
+		return
+	}
+	if tmpl == nil {
+		writeError(w, http.StatusNotFound, "notification template not found")
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		if !api.authorize(w, r, authz.ActionSettingsAlertsRead, templateResource(tmpl)) {
+			return
+		}
+		writeJSON(w, http.StatusOK, tmpl)
+	case http.MethodDelete:
+		if !api.authorize(w, r, authz.ActionSettingsAlertsWrite, templateResource(tmpl)) {
+			return
+		}
+		if err := api.store.DeleteNotificationTemplate(r.Context(), id); err != nil {
+			writeError(w, http.StatusInternalServerError, "failed to delete notification template")
+			return
+		}
+		api.audit(r, &storage.AuditEntry{
+			Action:     "notification_template.delete",
+			TargetType: "notification_template",
+			TargetID:   fmt.Sprintf("%d", id),
+			TenantID:   tmpl.TenantID,
+			Details:    fmt.Sprintf("Deleted %s notification template for %s (%s)", tmpl.ChannelType, templateScopeLabel(tmpl), api.actorLabel(r)),
+		})
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+	}
+}
+
+// templateRenderRequest is the body of the preview and test endpoints. The
+// template need not be saved. AlertID renders with a real alert's data
+// instead of sample data.
+type templateRenderRequest struct {
+	Template  storage.NotificationTemplate `json:"template"`
+	AlertID   int64                        `json:"alert_id,omitempty"`
+	ChannelID int64                        `json:"channel_id,omitempty"`
+}
+
+// handlePreviewNotificationTemplate renders a template without sending it.
+// POST /api/v1/notification-templates/preview
+func (api *API) handlePreviewNotificationTemplate(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+	req, data, ok := api.decodeTemplateRenderRequest(w, r, authz.ActionSettingsAlertsRead)
+	if !ok {
+		return
+	}
+
+	rendered, err := RenderNotificationTemplate(&req.Template, data)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, rendered)
+}
+
+// handleTestNotificationTemplate renders a template and sends it to a
+// channel of the same type.
+// POST /api/v1/notification-templates/test
+func (api *API) handleTestNotificationTemplate(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+	if api.notifier == nil {
+		writeError(w, http.StatusServiceUnavailable, "notification service not available")
+		return
+	}
+	req, data, ok := api.decodeTemplateRenderRequest(w, r, authz.ActionSettingsAlertsWrite)
+	if !ok {
+		return
+	}
+	if req.ChannelID == 0 {
+		writeError(w, http.StatusBadRequest, "channel_id is required")
+		return
+	}
+	channel, err := api.store.GetNotificationChannel(r.Context(), req.ChannelID)
+	if err != nil || channel == nil {
+		writeError(w, http.StatusNotFound, "notification channel not found")
+		return
+	}
+
+	if err := api.notifier.TestTemplate(r.Context(), channel, &req.Template, data); err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("test failed: %v", err))
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]string{"status": "ok", "message": "test notification sent"})
+}
+
+// decodeTemplateRenderRequest parses and authorizes a preview/test request
+// and gathers the data to render it with.
+func (api *API) decodeTemplateRenderRequest(w http.ResponseWriter, r *http.Request, action authz.Action) (*templateRenderRequest, TemplateData, bool) {
+	var req templateRenderRequest
+	if err := decodeJSON(r.Body, &req); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return nil, TemplateData{}, false
+	}
+	if !api.authorize(w, r, action, templateResource(&req.Template)) {
+		return nil, TemplateData{}, false
+	}
+	if err := req.Template.Validate(); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return nil, TemplateData{}, false
+	}
+	if req.AlertID == 0 {
+		return &req, api.sampleTemplateData(), true
+	}
+	if api.notifier == nil {
+		writeError(w, http.StatusServiceUnavailable, "notification service not available")
+		return nil, TemplateData{}, false
+	}
+	alert, err := api.store.GetAlert(r.Context(), req.AlertID)
+	if err != nil || alert == nil {
+		writeError(w, http.StatusNotFound, "alert not found")
+		return nil, TemplateData{}, false
+	}
+	return &req, api.notifier.TemplateData(r.Context(), alert), true
+}
+
+func (api *API) sampleTemplateData() TemplateData {
+	if api.notifier != nil {
+		return api.notifier.SampleTemplateData()
+	}
+	return SampleTemplateData("")
+}
+
+// templateResource scopes authorization to the template's tenant; global
+// templates are checked without a tenant.
+func templateResource(t *storage.NotificationTemplate) authz.ResourceRef {
+	if tenantID := strings.TrimSpace(t.TenantID); tenantID != "" {
+		return authz.ResourceRef{TenantIDs: []string{tenantID}}
+	}
+	return authz.ResourceRef{}
+}
+
+func templateScopeLabel(t *storage.NotificationTemplate) string {
+	if t.TenantID == "" {
+		return "all tenants"
+	}
+	return "tenant " + t.TenantID
+}
@@ -0,0 +1,154 @@
+package alerts
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	commonstorage "printmaster/common/storage"
+	"printmaster/server/storage"
+)
+
+// templateMockStore adds stored templates and entity lookups to the notifier
+// mock.
+type templateMockStore struct {
+	*mockNotifierStore
+	templates map[string]*storage.NotificationTemplate // tenant|type
+}
+
+func (m *templateMockStore) ResolveNotificationTemplate(ctx context.Context, tenantID, channelType string) (*storage.NotificationTemplate, error) {
+	if t, ok := m.templates[tenantID+"|"+channelType]; ok {
+		return t, nil
+	}
+	return m.templates["|"+channelType], nil
+}
+
+func (m *templateMockStore) GetDevice(ctx context.Context, serial string) (*storage.Device, error) {
+	return &storage.Device{
+		Device:  commonstorage.Device{Serial: serial, Manufacturer: "HP", Model: "M404", Location: "Lobby"},
+		AgentID: "agent-1",
+	}, nil
+}
+
+func (m *templateMockStore) GetAgent(ctx context.Context, agentID string) (*storage.Agent, error) {
+	return &storage.Agent{AgentID: agentID, Name: "HQ agent", TenantID: "tenant-1"}, nil
+}
+
+func (m *templateMockStore) GetTenant(ctx context.Context, id string) (*storage.Tenant, error) {
+	return &storage.Tenant{ID: id, Name: "Acme"}, nil
+}
+
+func (m *templateMockStore) GetLatestMetrics(ctx context.Context, serial string) (*storage.MetricsSnapshot, error) {
+	return &storage.MetricsSnapshot{Serial: serial, PageCount: 1234}, nil
+}
+
+func TestRenderNotificationTemplate(t *testing.T) {
+	t.Parallel()
+	data := SampleTemplateData("https://pm.example.com/")
+
+	email, err := RenderNotificationTemplate(DefaultNotificationTemplate(storage.ChannelTypeEmail), data)
+	if err != nil {
+		t.Fatalf("render default email: %v", err)
+	}
+	if email.Subject != "[WARNING] Toner low on Front Office Printer" {
+		t.Errorf("subject = %q", email.Subject)
+	}
+	if !strings.Contains(email.Text, "Tenant: Acme Corp") || !strings.Contains(email.Text, "https://pm.example.com/#alerts") {
+		t.Errorf("default email text missing context:\n%s", email.Text)
+	}
+
+	webhook, err := RenderNotificationTemplate(DefaultNotificationTemplate(storage.ChannelTypeWebhook), data)
+	if err != nil {
+		t.Fatalf("render default webhook: %v", err)
+	}
+	var payload map[string]interface{}
+	if err := json.Unmarshal([]byte(webhook.Text), &payload); err != nil || payload["alert_id"] != float64(12345) {
+		t.Errorf("default webhook payload = %s (%v)", webhook.Text, err)
+	}
+
+	// HTML bodies are escaped, subjects cannot inject headers
+	data.Alert.Title = "<b>Jam</b>\r\nBcc: x@example.com"
+	custom := &storage.NotificationTemplate{
+		ChannelType: storage.ChannelTypeEmail,
+		Subject:     "{{.Tenant.Name}}: {{.Alert.Title}}",
+		TextBody:    "{{.Device.Model}} at {{.Device.Location}}",
+		HTMLBody:    "<p>{{.Alert.Title}}</p>",
+	}
+	out, err := RenderNotificationTemplate(custom, data)
+	if err != nil {
+		t.Fatalf("render custom: %v", err)
+	}
+	if strings.ContainsAny(out.Subject, "\r\n") {
+		t.Errorf("subject not sanitized: %q", out.Subject)
+	}
+	if strings.Contains(out.HTML, "<b>") || out.Text != "LaserJet Pro M404 at Front office" {
+		t.Errorf("unexpected render: %+v", out)
+	}
+
+	// Unknown fields and invalid JSON are errors
+	if _, err := RenderNotificationTemplate(&storage.NotificationTemplate{ChannelType: storage.ChannelTypeEmail, Subject: "x", TextBody: "{{.Device.Colour}}"}, data); err == nil {
+		t.Error("expected error for unknown field")
+	}
+	if _, err := RenderNotificationTemplate(&storage.NotificationTemplate{ChannelType: storage.ChannelTypeWebhook, TextBody: `{"title": "{{.Alert.Title}}"}`}, data); err == nil {
+		t.Error("expected error for webhook body that is not valid JSON")
+	}
+}
+
+func TestNotifier_UsesTenantTemplate(t *testing.T) {
+	t.Parallel()
+
+	var body []byte
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ = io.ReadAll(r.Body)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	store := &templateMockStore{
+		mockNotifierStore: newMockNotifierStore(),
+		templates: map[string]*storage.NotificationTemplate{
+			"|webhook":         {ChannelType: storage.ChannelTypeWebhook, TextBody: `{"scope": "global"}`},
+			"tenant-1|webhook": {ChannelType: storage.ChannelTypeWebhook, TextBody: `{"tenant": {{json .Tenant.Name}}, "pages": {{.Metrics.PageCount}}, "rule": {{json .Rule.Name}}}`},
+		},
+	}
+	store.channels[1] = &storage.NotificationChannel{ID: 1, Name: "hook", Type: storage.ChannelTypeWebhook, Enabled: true, ConfigJSON: `{"url": "` + server.URL + `"}`}
+	store.rules[1] = &storage.AlertRule{ID: 1, Name: "Low toner", Enabled: true, ChannelIDs: []int64{1}}
+
+	notifier := NewNotifier(store, NotifierConfig{MaxRetries: 1, RetryDelay: time.Millisecond})
+	// The tenant comes from the device's agent
+	alert := &storage.Alert{ID: 9, RuleID: 1, Type: storage.AlertTypeSupplyLow, DeviceSerial: "SER1", Title: "Low"}
+	if err := notifier.NotifyForAlert(context.Background(), alert); err != nil {
+		t.Fatalf("NotifyForAlert: %v", err)
+	}
+	if string(body) != `{"tenant":"Acme","pages":1234,"rule":"Low toner"}` {
+		t.Errorf("webhook body = %s", body)
+	}
+
+	// A stored template that no longer renders falls back to the built-in one
+	store.templates["tenant-1|webhook"] = &storage.NotificationTemplate{ChannelType: storage.ChannelTypeWebhook, TextBody: `{{.Nope}}`}
+	alert.ID = 10
+	if err := notifier.NotifyForAlert(context.Background(), alert); err != nil {
+		t.Fatalf("NotifyForAlert: %v", err)
+	}
+	var payload map[string]interface{}
+	if err := json.Unmarshal(body, &payload); err != nil || payload["alert_id"] != float64(10) {
+		t.Errorf("expected built-in payload, got %s", body)
+	}
+}
+
+func TestBuildEmailMessage(t *testing.T) {
+	t.Parallel()
+	plain := string(buildEmailMessage("a@example.com", []string{"b@example.com"}, &RenderedNotification{Subject: "S", Text: "hello"}))
+	if !strings.Contains(plain, "Content-Type: text/plain; charset=UTF-8\r\n\r\nhello") {
+		t.Errorf("plain message = %q", plain)
+	}
+	multi := string(buildEmailMessage("a@example.com", []string{"b@example.com"}, &RenderedNotification{Subject: "S", Text: "hello", HTML: "<p>hello</p>"}))
+	if !strings.Contains(multi, "multipart/alternative") || !strings.Contains(multi, "text/html") || !strings.Contains(multi, "<p>hello</p>") {
+		t.Errorf("multipart message = %q", multi)
+	}
+}
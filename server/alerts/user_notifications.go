@@ -0,0 +1,357 @@
+package alerts
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"html"
+	"strings"
+	"time"
+
+	"printmaster/server/storage"
+)
+
+// UserNotificationStore is implemented by stores that keep per-user
+// notification preferences. Notifiers backed by other stores skip user
+// delivery.
+type UserNotificationStore interface {
+	ListEnabledUserNotificationPrefs(ctx context.Context) ([]*storage.UserNotificationPrefs, error)
+	SetUserNotificationProgress(ctx context.Context, userID, lastAlertID int64, digestAt *time.Time) error
+	ListAlertsAfter(ctx context.Context, afterID int64, limit int) ([]*storage.Alert, error)
+	ListUsers(ctx context.Context) ([]*storage.User, error)
+}
+
+const (
+	// userAlertBatch is how many new alerts are read per query
+	userAlertBatch = 500
+
+	// maxImmediatePerRun caps individual messages per user per run; beyond
+	// it the alerts are sent as one summary instead
+	maxImmediatePerRun = 10
+
+	// maxDigestLines caps the alerts listed in one digest
+	maxDigestLines = 200
+)
+
+// RunUserNotifications delivers per-user notifications every interval until
+// ctx is cancelled.
+func (n *Notifier) RunUserNotifications(ctx context.Context, interval time.Duration) {
+	if _, ok := n.store.(UserNotificationStore); !ok {
+		return
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := n.DeliverUserNotifications(ctx, time.Now()); err != nil {
+				n.logger.Warn("user notification run failed", "error", err)
+			}
+		}
+	}
+}
+
+// DeliverUserNotifications sends each user with notifications enabled the
+// new alerts matching their preferences: immediately, or as a digest once
+// their hourly or daily slot has passed. Incident child alerts are left to
+// their incident, and only alerts in tenants the user can see are sent.
+func (n *Notifier) DeliverUserNotifications(ctx context.Context, now time.Time) error {
+	us, ok := n.store.(UserNotificationStore)
+	if !ok {
+		return nil
+	}
+	prefs, err := us.ListEnabledUserNotificationPrefs(ctx)
+	if err != nil || len(prefs) == 0 {
+		return err
+	}
+	users, err := us.ListUsers(ctx)
+	if err != nil {
+		return fmt.Errorf("list users: %w", err)
+	}
+	byID := make(map[int64]*storage.User, len(users))
+	for _, u := range users {
+		byID[u.ID] = u
+	}
+
+	var quiet bool
+	if settings, err := n.store.GetAlertSettings(ctx); err == nil {
+		quiet = isInQuietHours(settings)
+	}
+
+	for _, p := range prefs {
+		user := byID[p.UserID]
+		if user == nil {
+			continue
+		}
+		if p.Frequency == storage.NotifyImmediate {
+			n.deliverImmediate(ctx, us, user, p, quiet, now)
+		} else if digestDue(p, now) {
+			n.deliverDigest(ctx, us, user, p, now)
+		}
+	}
+	return nil
+}
+
+// digestDue reports whether the user's current digest slot has not been
+// sent yet. Hourly slots start on the hour; daily slots at DigestHour UTC.
+func digestDue(p *storage.UserNotificationPrefs, now time.Time) bool {
+	now = now.UTC()
+	slot := now.Truncate(time.Hour)
+	if p.Frequency == storage.NotifyDaily {
+		slot = time.Date(now.Year(), now.Month(), now.Day(), p.DigestHour, 0, 0, 0, time.UTC)
+		if now.Before(slot) {
+			slot = slot.Add(-24 * time.Hour)
+		}
+	}
+	return p.LastDigestAt == nil || p.LastDigestAt.Before(slot)
+}
+
+func (n *Notifier) deliverImmediate(ctx context.Context, us UserNotificationStore, user *storage.User, p *storage.UserNotificationPrefs, quiet bool, now time.Time) {
+	alerts, err := us.ListAlertsAfter(ctx, p.LastAlertID, userAlertBatch)
+	if err != nil {
+		n.logger.Warn("failed to list alerts for user notifications", "user", user.Username, "error", err)
+		return
+	}
+	if len(alerts) == 0 {
+		return
+	}
+	var matched []*storage.Alert
+	for _, a := range alerts {
+		if !userWantsAlert(user, p, a) {
+			continue
+		}
+		// Quiet hours hold back everything but critical alert types, as for rule channels
+		if quiet && !isCriticalAlertType(a.Type) {
+			continue
+		}
+		matched = append(matched, a)
+	}
+
+	if len(matched) > maxImmediatePerRun {
+		n.sendUserDigest(ctx, user, p, matched, len(matched), now)
+	} else {
+		for _, a := range matched {
+			n.sendUserAlert(ctx, user, p, a)
+		}
+	}
+	if err := us.SetUserNotificationProgress(ctx, user.ID, alerts[len(alerts)-1].ID, nil); err != nil {
+		n.logger.Warn("failed to record user notification progress", "user", user.Username, "error", err)
+	}
+}
+
+func (n *Notifier) deliverDigest(ctx context.Context, us UserNotificationStore, user *storage.User, p *storage.UserNotificationPrefs, now time.Time) {
+	var matched []*storage.Alert
+	total := 0
+	cursor := p.LastAlertID
+	for {
+		alerts, err := us.ListAlertsAfter(ctx, cursor, userAlertBatch)
+		if err != nil {
+			n.logger.Warn("failed to list alerts for user digest", "user", user.Username, "error", err)
+			return
+		}
+		for _, a := range alerts {
+			if userWantsAlert(user, p, a) {
+				total++
+				if len(matched) < maxDigestLines {
+					matched = append(matched, a)
+				}
+			}
+		}
+		if len(alerts) > 0 {
+			cursor = alerts[len(alerts)-1].ID
+		}
+		if len(alerts) < userAlertBatch {
+			break
+		}
+	}
+
+	if total > 0 {
+		n.sendUserDigest(ctx, user, p, matched, total, now)
+	}
+	if err := us.SetUserNotificationProgress(ctx, user.ID, cursor, &now); err != nil {
+		n.logger.Warn("failed to record user digest", "user", user.Username, "error", err)
+	}
+}
+
+// userWantsAlert applies the user's filters and tenant access to an alert.
+func userWantsAlert(user *storage.User, p *storage.UserNotificationPrefs, a *storage.Alert) bool {
+	if a.ParentAlertID != nil || !p.Wants(a) {
+		return false
+	}
+	if storage.NormalizeRole(string(user.Role)) == storage.RoleAdmin {
+		return true
+	}
+	if a.TenantID == "" {
+		return false
+	}
+	if a.TenantID == user.TenantID {
+		return true
+	}
+	for _, id := range user.TenantIDs {
+		if id == a.TenantID {
+			return true
+		}
+	}
+	return false
+}
+
+func userEmail(user *storage.User, p *storage.UserNotificationPrefs) string {
+	if p.EmailAddress != "" {
+		return p.EmailAddress
+	}
+	return user.Email
+}
+
+// sendUserAlert sends one alert to the user's email and webhook, rendered
+// with the same templates as rule channels.
+func (n *Notifier) sendUserAlert(ctx context.Context, user *storage.User, p *storage.UserNotificationPrefs, alert *storage.Alert) {
+	sent := false
+	if p.EmailEnabled {
+		if addr := userEmail(user, p); addr == "" {
+			n.logger.Warn("user notification skipped: no email address", "user", user.Username)
+		} else if msg, err := n.renderForChannel(ctx, storage.ChannelTypeEmail, alert); err != nil {
+			n.logger.Error("failed to render user notification", "user", user.Username, "error", err)
+		} else if err := n.sendEmail(ctx, map[string]interface{}{"to": []interface{}{addr}}, msg); err != nil {
+			n.logger.Error("failed to email user notification", "user", user.Username, "alert_id", alert.ID, "error", err)
+		} else {
+			sent = true
+		}
+	}
+	if p.WebhookURL != "" {
+		if msg, err := n.renderForChannel(ctx, storage.ChannelTypeWebhook, alert); err != nil {
+			n.logger.Error("failed to render user notification", "user", user.Username, "error", err)
+		} else if err := n.sendWebhook(ctx, map[string]interface{}{"url": p.WebhookURL}, msg); err != nil {
+			n.logger.Error("failed to post user notification", "user", user.Username, "alert_id", alert.ID, "error", err)
+		} else {
+			sent = true
+		}
+	}
+	if sent {
+		n.logger.Info("user notification sent", "user", user.Username, "alert_id", alert.ID)
+	}
+}
+
+// sendUserDigest sends alerts as one summary message. total may exceed
+// len(alerts) when the list was capped.
+func (n *Notifier) sendUserDigest(ctx context.Context, user *storage.User, p *storage.UserNotificationPrefs, alerts []*storage.Alert, total int, now time.Time) {
+	sent := false
+	if p.EmailEnabled {
+		if addr := userEmail(user, p); addr == "" {
+			n.logger.Warn("user digest skipped: no email address", "user", user.Username)
+		} else if err := n.sendEmail(ctx, map[string]interface{}{"to": []interface{}{addr}}, n.renderDigestEmail(alerts, total)); err != nil {
+			n.logger.Error("failed to email user digest", "user", user.Username, "error", err)
+		} else {
+			sent = true
+		}
+	}
+	if p.WebhookURL != "" {
+		body, err := json.Marshal(digestPayload(user, alerts, total, now))
+		if err == nil {
+			err = n.sendWebhook(ctx, map[string]interface{}{"url": p.WebhookURL}, &RenderedNotification{Text: string(body)})
+		}
+		if err != nil {
+			n.logger.Error("failed to post user digest", "user", user.Username, "error", err)
+		} else {
+			sent = true
+		}
+	}
+	if sent {
+		n.logger.Info("user digest sent", "user", user.Username, "alerts", total)
+	}
+}
+
+func digestSubject(alerts []*storage.Alert, total int) string {
+	critical := 0
+	for _, a := range alerts {
+		if a.Severity == storage.AlertSeverityCritical {
+			critical++
+		}
+	}
+	noun := "alerts"
+	if total == 1 {
+		noun = "alert"
+	}
+	if critical > 0 {
+		return fmt.Sprintf("PrintMaster digest: %d %s (%d critical)", total, noun, critical)
+	}
+	return fmt.Sprintf("PrintMaster digest: %d %s", total, noun)
+}
+
+func (n *Notifier) renderDigestEmail(alerts []*storage.Alert, total int) *RenderedNotification {
+	links := templateLinks(n.config.BaseURL)
+	var text, body strings.Builder
+	for _, a := range alerts {
+		where := a.DeviceSerial
+		if where == "" {
+			where = a.AgentID
+		}
+		fmt.Fprintf(&text, "[%s] %s  %s", strings.ToUpper(a.Severity), a.TriggeredAt.UTC().Format("2006-01-02 15:04"), a.Title)
+		if where != "" {
+			fmt.Fprintf(&text, " (%s)", where)
+		}
+		text.WriteString("\n")
+		title := html.EscapeString(a.Title)
+		if ack, _ := n.actionLinks(a.ID); ack != "" {
+			fmt.Fprintf(&text, "  Acknowledge: %s\n", ack)
+			title += fmt.Sprintf(` (<a href="%s">acknowledge</a>)`, html.EscapeString(ack))
+		}
+		fmt.Fprintf(&body, "<tr><td>%s</td><td>%s</td><td>%s</td><td>%s</td></tr>",
+			html.EscapeString(strings.ToUpper(a.Severity)), a.TriggeredAt.UTC().Format("2006-01-02 15:04"),
+			title, html.EscapeString(where))
+	}
+	more := ""
+	if total > len(alerts) {
+		more = fmt.Sprintf("...and %d more.\n", total-len(alerts))
+		text.WriteString(more)
+	}
+	if links.Alerts != "" {
+		fmt.Fprintf(&text, "\nView alerts: %s\n", links.Alerts)
+	}
+
+	var h strings.Builder
+	h.WriteString(`<table cellpadding="4" style="border-collapse:collapse"><tr><th align="left">Severity</th><th align="left">Triggered (UTC)</th><th align="left">Alert</th><th align="left">Device</th></tr>`)
+	h.WriteString(body.String())
+	h.WriteString("</table>")
+	if more != "" {
+		fmt.Fprintf(&h, "<p>%s</p>", html.EscapeString(strings.TrimSpace(more)))
+	}
+	if links.Alerts != "" {
+		fmt.Fprintf(&h, `<p><a href="%s">View alerts</a></p>`, html.EscapeString(links.Alerts))
+	}
+	return &RenderedNotification{
+		Subject: sanitizeEmailHeader(digestSubject(alerts, total)),
+		Text:    text.String(),
+		HTML:    h.String(),
+	}
+}
+
+type digestAlert struct {
+	ID           int64     `json:"id"`
+	Type         string    `json:"type"`
+	Severity     string    `json:"severity"`
+	Title        string    `json:"title"`
+	Message      string    `json:"message,omitempty"`
+	TenantID     string    `json:"tenant_id,omitempty"`
+	DeviceSerial string    `json:"device_serial,omitempty"`
+	AgentID      string    `json:"agent_id,omitempty"`
+	TriggeredAt  time.Time `json:"triggered_at"`
+}
+
+func digestPayload(user *storage.User, alerts []*storage.Alert, total int, now time.Time) map[string]interface{} {
+	items := make([]digestAlert, 0, len(alerts))
+	for _, a := range alerts {
+		items = append(items, digestAlert{
+			ID: a.ID, Type: a.Type, Severity: a.Severity, Title: a.Title, Message: a.Message,
+			TenantID: a.TenantID, DeviceSerial: a.DeviceSerial, AgentID: a.AgentID, TriggeredAt: a.TriggeredAt,
+		})
+	}
+	return map[string]interface{}{
+		"event":   "alert_digest",
+		"user":    user.Username,
+		"sent_at": now.UTC(),
+		"total":   total,
+		"alerts":  items,
+	}
+}
@@ -0,0 +1,174 @@
+package alerts
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"printmaster/server/storage"
+)
+
+// mockUserNotificationStore adds per-user preferences to mockNotifierStore.
+type mockUserNotificationStore struct {
+	*mockNotifierStore
+	prefs  []*storage.UserNotificationPrefs
+	users  []*storage.User
+	alerts []*storage.Alert
+}
+
+func (m *mockUserNotificationStore) ListEnabledUserNotificationPrefs(ctx context.Context) ([]*storage.UserNotificationPrefs, error) {
+	var out []*storage.UserNotificationPrefs
+	for _, p := range m.prefs {
+		if p.Enabled {
+			out = append(out, p)
+		}
+	}
+	return out, nil
+}
+
+func (m *mockUserNotificationStore) SetUserNotificationProgress(ctx context.Context, userID, lastAlertID int64, digestAt *time.Time) error {
+	for _, p := range m.prefs {
+		if p.UserID == userID {
+			p.LastAlertID = lastAlertID
+			if digestAt != nil {
+				t := *digestAt
+				p.LastDigestAt = &t
+			}
+		}
+	}
+	return nil
+}
+
+func (m *mockUserNotificationStore) ListAlertsAfter(ctx context.Context, afterID int64, limit int) ([]*storage.Alert, error) {
+	var out []*storage.Alert
+	for _, a := range m.alerts {
+		if a.ID > afterID && len(out) < limit {
+			out = append(out, a)
+		}
+	}
+	return out, nil
+}
+
+func (m *mockUserNotificationStore) ListUsers(ctx context.Context) ([]*storage.User, error) {
+	return m.users, nil
+}
+
+func TestDeliverUserNotifications(t *testing.T) {
+	t.Parallel()
+
+	var mu sync.Mutex
+	received := map[string][][]byte{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		mu.Lock()
+		received[r.URL.Path] = append(received[r.URL.Path], body)
+		mu.Unlock()
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	now := time.Date(2026, 3, 2, 9, 30, 0, 0, time.UTC)
+	store := &mockUserNotificationStore{
+		mockNotifierStore: newMockNotifierStore(),
+		users: []*storage.User{
+			{ID: 1, Username: "tech", Role: storage.RoleOperator, TenantID: "tenant-a"},
+			{ID: 2, Username: "manager", Role: storage.RoleViewer, TenantIDs: []string{"tenant-a", "tenant-b"}},
+			{ID: 3, Username: "off", Role: storage.RoleAdmin},
+		},
+		prefs: []*storage.UserNotificationPrefs{
+			{UserID: 1, Enabled: true, MinSeverity: "warning", AlertTypes: []string{"toner_low", "paper_jam"},
+				WebhookURL: server.URL + "/tech", Frequency: storage.NotifyImmediate},
+			{UserID: 2, Enabled: true, MinSeverity: "critical", WebhookURL: server.URL + "/manager",
+				Frequency: storage.NotifyDaily, DigestHour: 8},
+			{UserID: 3, Enabled: false, MinSeverity: "info", WebhookURL: server.URL + "/off", Frequency: storage.NotifyImmediate},
+		},
+	}
+	parent := int64(1)
+	store.alerts = []*storage.Alert{
+		{ID: 1, Type: "toner_low", Severity: "warning", TenantID: "tenant-a", Title: "Toner low", TriggeredAt: now},
+		{ID: 2, Type: "toner_low", Severity: "info", TenantID: "tenant-a", Title: "Toner info", TriggeredAt: now},
+		{ID: 3, Type: "device_offline", Severity: "critical", TenantID: "tenant-a", Title: "Offline A", TriggeredAt: now},
+		{ID: 4, Type: "paper_jam", Severity: "critical", TenantID: "tenant-b", Title: "Jam B", TriggeredAt: now},
+		{ID: 5, Type: "paper_jam", Severity: "warning", TenantID: "tenant-a", Title: "Child jam", ParentAlertID: &parent, TriggeredAt: now},
+	}
+
+	n := NewNotifier(store, NotifierConfig{RetryDelay: time.Millisecond})
+	if err := n.DeliverUserNotifications(context.Background(), now); err != nil {
+		t.Fatalf("DeliverUserNotifications: %v", err)
+	}
+
+	// The technician gets only the warning toner alert in their tenant
+	if got := len(received["/tech"]); got != 1 {
+		t.Fatalf("tech received %d notifications, want 1", got)
+	}
+	var payload map[string]interface{}
+	if err := json.Unmarshal(received["/tech"][0], &payload); err != nil {
+		t.Fatalf("tech payload: %v", err)
+	}
+	if payload["alert_id"] != float64(1) {
+		t.Errorf("tech payload = %v, want alert 1", payload)
+	}
+
+	// The manager's daily digest slot (08:00) has passed: both critical alerts in one message
+	if got := len(received["/manager"]); got != 1 {
+		t.Fatalf("manager received %d digests, want 1", got)
+	}
+	var digest struct {
+		Event  string `json:"event"`
+		Total  int    `json:"total"`
+		Alerts []struct {
+			ID int64 `json:"id"`
+		} `json:"alerts"`
+	}
+	if err := json.Unmarshal(received["/manager"][0], &digest); err != nil {
+		t.Fatalf("digest payload: %v", err)
+	}
+	if digest.Event != "alert_digest" || digest.Total != 2 || digest.Alerts[0].ID != 3 || digest.Alerts[1].ID != 4 {
+		t.Errorf("digest = %+v", digest)
+	}
+	if len(received["/off"]) != 0 {
+		t.Errorf("disabled user was notified")
+	}
+	if store.prefs[0].LastAlertID != 5 || store.prefs[1].LastAlertID != 5 || store.prefs[1].LastDigestAt == nil {
+		t.Errorf("progress not recorded: %+v %+v", store.prefs[0], store.prefs[1])
+	}
+
+	// A second run in the same slot sends nothing new
+	store.alerts = append(store.alerts, &storage.Alert{ID: 6, Type: "paper_jam", Severity: "critical", TenantID: "tenant-a", Title: "Jam A", TriggeredAt: now})
+	if err := n.DeliverUserNotifications(context.Background(), now.Add(time.Hour)); err != nil {
+		t.Fatalf("DeliverUserNotifications: %v", err)
+	}
+	if len(received["/tech"]) != 2 || len(received["/manager"]) != 1 {
+		t.Errorf("after second run: tech %d, manager %d", len(received["/tech"]), len(received["/manager"]))
+	}
+}
+
+func TestDigestDue(t *testing.T) {
+	t.Parallel()
+	at := func(h, m int) time.Time { return time.Date(2026, 3, 2, h, m, 0, 0, time.UTC) }
+	sent := func(tm time.Time) *time.Time { return &tm }
+
+	tests := []struct {
+		name string
+		p    storage.UserNotificationPrefs
+		now  time.Time
+		want bool
+	}{
+		{"never sent", storage.UserNotificationPrefs{Frequency: storage.NotifyHourly}, at(10, 5), true},
+		{"hourly same hour", storage.UserNotificationPrefs{Frequency: storage.NotifyHourly, LastDigestAt: sent(at(10, 1))}, at(10, 59), false},
+		{"hourly next hour", storage.UserNotificationPrefs{Frequency: storage.NotifyHourly, LastDigestAt: sent(at(10, 1))}, at(11, 0), true},
+		{"daily before hour", storage.UserNotificationPrefs{Frequency: storage.NotifyDaily, DigestHour: 8, LastDigestAt: sent(at(8, 0).Add(-24 * time.Hour))}, at(7, 59), false},
+		{"daily after hour", storage.UserNotificationPrefs{Frequency: storage.NotifyDaily, DigestHour: 8, LastDigestAt: sent(at(8, 0).Add(-24 * time.Hour))}, at(8, 1), true},
+		{"daily already sent", storage.UserNotificationPrefs{Frequency: storage.NotifyDaily, DigestHour: 8, LastDigestAt: sent(at(8, 1))}, at(23, 0), false},
+	}
+	for _, tt := range tests {
+		if got := digestDue(&tt.p, tt.now); got != tt.want {
+			t.Errorf("%s: digestDue = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
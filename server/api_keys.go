@@ -0,0 +1,606 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"printmaster/server/authz"
+	"printmaster/server/storage"
+)
+
+// API keys are API tokens (sessions of kind api) that customers use to
+// integrate directly. Requests made with them are counted per key, day and
+// endpoint so owners and their tenants' operators can see what an
+// integration calls, how often it fails and when it was last used. Owners are
+// warned by email before a key expires.
+
+const (
+	apiKeyDefaultTTLDays = 90
+	apiKeyMaxTTLDays     = 365
+	// apiKeyExpiryWarning is how long before expiry the owner is warned.
+	apiKeyExpiryWarning = 14 * 24 * time.Hour
+	// apiKeyUsageRetention is how long daily usage rows are kept.
+	apiKeyUsageRetention = 90 * 24 * time.Hour
+	apiKeyUsageFlush     = time.Minute
+	apiKeyTopEndpoints   = 5
+)
+
+// apiUsage collects API key requests in memory between flushes.
+var apiUsage = newAPIUsageTracker()
+
+type apiUsageKey struct {
+	token, day, endpoint string
+}
+
+type apiUsageTracker struct {
+	mu     sync.Mutex
+	counts map[apiUsageKey]*storage.APITokenUsage
+}
+
+func newAPIUsageTracker() *apiUsageTracker {
+	return &apiUsageTracker{counts: make(map[apiUsageKey]*storage.APITokenUsage)}
+}
+
+// record counts one request made with the token with the given stored hash.
+func (t *apiUsageTracker) record(tokenHash, method, path string, status int, at time.Time) {
+	key := apiUsageKey{token: tokenHash, day: storage.APITokenUsageDay(at), endpoint: apiUsageEndpoint(method, path)}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	u := t.counts[key]
+	if u == nil {
+		u = &storage.APITokenUsage{TokenHash: key.token, Day: key.day, Endpoint: key.endpoint}
+		t.counts[key] = u
+	}
+	u.Requests++
+	if status >= 400 {
+		u.Errors++
+	}
+	if at.After(u.LastUsedAt) {
+		u.LastUsedAt = at.UTC()
+	}
+}
+
+// flush writes the collected counts. Counts that fail to save are kept for
+// the next flush.
+func (t *apiUsageTracker) flush(ctx context.Context, store storage.Store) error {
+	t.mu.Lock()
+	pending := t.counts
+	t.counts = make(map[apiUsageKey]*storage.APITokenUsage)
+	t.mu.Unlock()
+	if len(pending) == 0 || store == nil {
+		return nil
+	}
+	batch := make([]storage.APITokenUsage, 0, len(pending))
+	for _, u := range pending {
+		batch = append(batch, *u)
+	}
+	if err := store.RecordAPITokenUsage(ctx, batch); err != nil {
+		t.mu.Lock()
+		for key, u := range pending {
+			if cur := t.counts[key]; cur != nil {
+				cur.Requests += u.Requests
+				cur.Errors += u.Errors
+				if u.LastUsedAt.After(cur.LastUsedAt) {
+					cur.LastUsedAt = u.LastUsedAt
+				}
+			} else {
+				t.counts[key] = u
+			}
+		}
+		t.mu.Unlock()
+		return err
+	}
+	return nil
+}
+
+// apiUsageEndpoint groups a request by method and route: path segments that
+// look like identifiers (numbers, serials, UUIDs, hashes) become {id}.
+func apiUsageEndpoint(method, path string) string {
+	segs := strings.Split(strings.Trim(path, "/"), "/")
+	for i, seg := range segs {
+		if apiUsageIDSegment(seg) {
+			segs[i] = "{id}"
+		}
+	}
+	endpoint := method + " /" + strings.Join(segs, "/")
+	if len(endpoint) > 200 {
+		endpoint = endpoint[:200]
+	}
+	return endpoint
+}
+
+func apiUsageIDSegment(seg string) bool {
+	if seg == "" {
+		return false
+	}
+	digits := 0
+	for _, c := range seg {
+		if c >= '0' && c <= '9' {
+			digits++
+		}
+	}
+	return digits == len(seg) || len(seg) >= 16 || (digits > 0 && len(seg) >= 6)
+}
+
+// apiUsageStatusWriter records the status of a response made to an API key.
+type apiUsageStatusWriter struct {
+	http.ResponseWriter
+	status      int
+	wroteHeader bool
+}
+
+func (w *apiUsageStatusWriter) WriteHeader(code int) {
+	if !w.wroteHeader {
+		w.status = code
+		w.wroteHeader = true
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *apiUsageStatusWriter) Write(b []byte) (int, error) {
+	w.wroteHeader = true
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *apiUsageStatusWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (w *apiUsageStatusWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	if h, ok := w.ResponseWriter.(http.Hijacker); ok {
+		return h.Hijack()
+	}
+	return nil, nil, fmt.Errorf("hijacking not supported")
+}
+
+func (w *apiUsageStatusWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// runAPIKeyMaintenance flushes usage counts every minute and, hourly, warns
+// owners of keys about to expire and prunes old usage rows.
+func runAPIKeyMaintenance(ctx context.Context) {
+	flush := time.NewTicker(apiKeyUsageFlush)
+	defer flush.Stop()
+	hourly := time.NewTicker(time.Hour)
+	defer hourly.Stop()
+	warnExpiringAPIKeys(ctx, time.Now())
+	for {
+		select {
+		case <-ctx.Done():
+			flushCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			if err := apiUsage.flush(flushCtx, serverStore); err != nil {
+				logWarn("Failed to save API key usage on shutdown", "error", err)
+			}
+			cancel()
+			return
+		case <-flush.C:
+			if err := apiUsage.flush(ctx, serverStore); err != nil {
+				logWarn("Failed to save API key usage", "error", err)
+			}
+		case now := <-hourly.C:
+			warnExpiringAPIKeys(ctx, now)
+			if n, err := serverStore.DeleteAPITokenUsageBefore(ctx, now.Add(-apiKeyUsageRetention)); err != nil {
+				logWarn("Failed to prune API key usage", "error", err)
+			} else if n > 0 {
+				logDebug("Pruned API key usage", "rows", n)
+			}
+		}
+	}
+}
+
+// warnExpiringAPIKeys emails the owner of each key that expires within the
+// warning window, once per key.
+func warnExpiringAPIKeys(ctx context.Context, now time.Time) {
+	if serverStore == nil {
+		return
+	}
+	keys, err := serverStore.ListAPITokensExpiring(ctx, now.Add(apiKeyExpiryWarning))
+	if err != nil {
+		logWarn("Failed to list expiring API keys", "error", err)
+		return
+	}
+	for _, k := range keys {
+		label := apiKeyLabel(k)
+		days := int(k.ExpiresAt.Sub(now).Hours() / 24)
+		logWarn("API key expires soon", "id", apiTokenID(k.Token), "name", k.Name, "user", k.Username, "expires_at", k.ExpiresAt.UTC().Format(time.RFC3339))
+		owner, err := serverStore.GetUserByID(ctx, k.UserID)
+		if err == nil && owner != nil && owner.Email != "" {
+			subject := fmt.Sprintf("PrintMaster API key %q expires in %d day(s)", label, days)
+			body := fmt.Sprintf("Your PrintMaster API key %q (id %s) expires on %s.\n\n"+
+				"Create a new key and update the integrations that use this one before it expires; requests made with an expired key are rejected.\n",
+				label, apiTokenID(k.Token), k.ExpiresAt.UTC().Format("2006-01-02 15:04 MST"))
+			if err := sendHTMLEmail(owner.Email, subject, "", body); err != nil {
+				// The audit entry and the key list still flag the key
+				logDebug("Could not email API key expiry warning", "id", apiTokenID(k.Token), "error", err)
+			}
+		}
+		if err := serverStore.MarkAPITokenExpiryWarned(ctx, k.Token, now); err != nil {
+			logWarn("Failed to record API key expiry warning", "id", apiTokenID(k.Token), "error", err)
+			continue
+		}
+		tenantID := ""
+		if owner != nil {
+			tenantID = owner.TenantID
+		}
+		logAuditEntry(ctx, &storage.AuditEntry{
+			ActorType:  storage.AuditActorSystem,
+			ActorID:    "api-keys",
+			ActorName:  "api key expiry",
+			Action:     "api_token.expiring",
+			TargetType: "api_token",
+			TargetID:   apiTokenID(k.Token),
+			TenantID:   tenantID,
+			Severity:   storage.AuditSeverityWarn,
+			Details:    fmt.Sprintf("API key %q of %s expires %s", label, k.Username, k.ExpiresAt.UTC().Format(time.RFC3339)),
+		})
+	}
+}
+
+func apiKeyLabel(k *storage.Session) string {
+	if k.Name != "" {
+		return k.Name
+	}
+	return apiTokenID(k.Token)
+}
+
+// apiKeyEndpointUsage is the usage of one endpoint over the reporting window.
+type apiKeyEndpointUsage struct {
+	Endpoint   string    `json:"endpoint"`
+	Requests   int64     `json:"requests"`
+	Errors     int64     `json:"errors"`
+	ErrorRate  float64   `json:"error_rate"`
+	LastUsedAt time.Time `json:"last_used_at"`
+}
+
+// apiKeyView is an API key as listed to owners and operators. The token
+// itself is only returned once, when the key is created.
+type apiKeyView struct {
+	ID            string                `json:"id"`
+	Name          string                `json:"name,omitempty"`
+	UserID        int64                 `json:"user_id"`
+	Username      string                `json:"username"`
+	CreatedAt     time.Time             `json:"created_at"`
+	ExpiresAt     time.Time             `json:"expires_at"`
+	ExpiresInDays int                   `json:"expires_in_days"`
+	ExpiringSoon  bool                  `json:"expiring_soon"`
+	LastUsedAt    *time.Time            `json:"last_used_at,omitempty"`
+	Requests      int64                 `json:"requests"`
+	Errors        int64                 `json:"errors"`
+	ErrorRate     float64               `json:"error_rate"`
+	TopEndpoints  []apiKeyEndpointUsage `json:"top_endpoints,omitempty"`
+}
+
+// apiKeyDayUsage is one day of a key's requests.
+type apiKeyDayUsage struct {
+	Day      string `json:"day"`
+	Requests int64  `json:"requests"`
+	Errors   int64  `json:"errors"`
+}
+
+func errorRate(requests, errors int64) float64 {
+	if requests == 0 {
+		return 0
+	}
+	return float64(errors) / float64(requests)
+}
+
+// summarizeAPIKey builds the view of k from its usage rows.
+func summarizeAPIKey(k *storage.Session, usage []storage.APITokenUsage, now time.Time, top int) apiKeyView {
+	v := apiKeyView{
+		ID:            apiTokenID(k.Token),
+		Name:          k.Name,
+		UserID:        k.UserID,
+		Username:      k.Username,
+		CreatedAt:     k.CreatedAt,
+		ExpiresAt:     k.ExpiresAt,
+		ExpiresInDays: int(k.ExpiresAt.Sub(now).Hours() / 24),
+		ExpiringSoon:  k.ExpiresAt.Sub(now) <= apiKeyExpiryWarning,
+	}
+	byEndpoint := map[string]*apiKeyEndpointUsage{}
+	for _, u := range usage {
+		v.Requests += u.Requests
+		v.Errors += u.Errors
+		if v.LastUsedAt == nil || u.LastUsedAt.After(*v.LastUsedAt) {
+			t := u.LastUsedAt
+			v.LastUsedAt = &t
+		}
+		e := byEndpoint[u.Endpoint]
+		if e == nil {
+			e = &apiKeyEndpointUsage{Endpoint: u.Endpoint}
+			byEndpoint[u.Endpoint] = e
+		}
+		e.Requests += u.Requests
+		e.Errors += u.Errors
+		if u.LastUsedAt.After(e.LastUsedAt) {
+			e.LastUsedAt = u.LastUsedAt
+		}
+	}
+	v.ErrorRate = errorRate(v.Requests, v.Errors)
+	for _, e := range byEndpoint {
+		e.ErrorRate = errorRate(e.Requests, e.Errors)
+		v.TopEndpoints = append(v.TopEndpoints, *e)
+	}
+	sort.Slice(v.TopEndpoints, func(i, j int) bool {
+		if v.TopEndpoints[i].Requests != v.TopEndpoints[j].Requests {
+			return v.TopEndpoints[i].Requests > v.TopEndpoints[j].Requests
+		}
+		return v.TopEndpoints[i].Endpoint < v.TopEndpoints[j].Endpoint
+	})
+	if top > 0 && len(v.TopEndpoints) > top {
+		v.TopEndpoints = v.TopEndpoints[:top]
+	}
+	return v
+}
+
+// visibleAPIKeys returns the unexpired API keys the caller may see: all for
+// admins, otherwise their own and those of non-admin users in their tenants.
+func visibleAPIKeys(ctx context.Context, p *Principal, now time.Time) ([]*storage.Session, error) {
+	sessions, err := serverStore.ListSessions(ctx)
+	if err != nil {
+		return nil, err
+	}
+	owners := map[int64]*Principal{}
+	var out []*storage.Session
+	for _, s := range sessions {
+		if s.Kind != storage.SessionKindAPI || !s.ExpiresAt.After(now) {
+			continue
+		}
+		if p.IsAdmin() || (p.User != nil && s.UserID == p.User.ID) {
+			out = append(out, s)
+			continue
+		}
+		owner, ok := owners[s.UserID]
+		if !ok {
+			if u, err := serverStore.GetUserByID(ctx, s.UserID); err == nil {
+				owner = newPrincipal(u)
+			}
+			owners[s.UserID] = owner
+		}
+		if owner == nil || owner.IsAdmin() {
+			continue
+		}
+		for _, tid := range owner.TenantIDs {
+			if p.CanAccessTenant(tid) {
+				out = append(out, s)
+				break
+			}
+		}
+	}
+	return out, nil
+}
+
+// findVisibleAPIKey returns the visible key with the given id, or nil.
+func findVisibleAPIKey(ctx context.Context, p *Principal, id string, now time.Time) (*storage.Session, error) {
+	keys, err := visibleAPIKeys(ctx, p, now)
+	if err != nil {
+		return nil, err
+	}
+	for _, k := range keys {
+		if apiTokenID(k.Token) == id {
+			return k, nil
+		}
+	}
+	return nil, nil
+}
+
+func apiKeyUsageDays(r *http.Request) int {
+	days := 30
+	if v, err := strconv.Atoi(r.URL.Query().Get("days")); err == nil && v > 0 {
+		days = v
+	}
+	if max := int(apiKeyUsageRetention / (24 * time.Hour)); days > max {
+		days = max
+	}
+	return days
+}
+
+// handleAPIKeys lists visible keys with usage over ?days= (default 30) or
+// creates a key for the caller.
+func handleAPIKeys(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		if !authorizeOrReject(w, r, authz.ActionAPIKeysRead, authz.ResourceRef{}) {
+			return
+		}
+		ctx := r.Context()
+		now := time.Now().UTC()
+		if err := apiUsage.flush(ctx, serverStore); err != nil {
+			logWarn("Failed to save API key usage", "error", err)
+		}
+		keys, err := visibleAPIKeys(ctx, getPrincipal(r), now)
+		if err != nil {
+			logError("Failed to list API keys", "error", err)
+			http.Error(w, "failed to list API keys", http.StatusInternalServerError)
+			return
+		}
+		days := apiKeyUsageDays(r)
+		usage, err := serverStore.ListAPITokenUsage(ctx, "", now.AddDate(0, 0, -(days-1)))
+		if err != nil {
+			logError("Failed to load API key usage", "error", err)
+			http.Error(w, "failed to load API key usage", http.StatusInternalServerError)
+			return
+		}
+		byToken := map[string][]storage.APITokenUsage{}
+		for _, u := range usage {
+			byToken[u.TokenHash] = append(byToken[u.TokenHash], u)
+		}
+		views := make([]apiKeyView, 0, len(keys))
+		for _, k := range keys {
+			views = append(views, summarizeAPIKey(k, byToken[k.Token], now, apiKeyTopEndpoints))
+		}
+		sort.Slice(views, func(i, j int) bool { return views[i].CreatedAt.After(views[j].CreatedAt) })
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{"days": days, "keys": views})
+	case http.MethodPost:
+		if !authorizeOrReject(w, r, authz.ActionAPIKeysWrite, authz.ResourceRef{}) {
+			return
+		}
+		p := getPrincipal(r)
+		if p == nil || p.User == nil {
+			http.Error(w, "unauthenticated", http.StatusUnauthorized)
+			return
+		}
+		var req struct {
+			Name    string `json:"name"`
+			TTLDays int    `json:"ttl_days"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "invalid json", http.StatusBadRequest)
+			return
+		}
+		if strings.TrimSpace(req.Name) == "" {
+			http.Error(w, "name required", http.StatusBadRequest)
+			return
+		}
+		if req.TTLDays == 0 {
+			req.TTLDays = apiKeyDefaultTTLDays
+		}
+		if req.TTLDays < 1 || req.TTLDays > apiKeyMaxTTLDays {
+			http.Error(w, fmt.Sprintf("ttl_days must be between 1 and %d", apiKeyMaxTTLDays), http.StatusBadRequest)
+			return
+		}
+		ses, err := serverStore.CreateAPIToken(r.Context(), p.User.ID, req.Name, req.TTLDays*24*60)
+		if err != nil {
+			logError("Failed to create API key", "user_id", p.User.ID, "error", err)
+			http.Error(w, "failed to create API key", http.StatusInternalServerError)
+			return
+		}
+		id := apiTokenID(storage.TokenHash(ses.Token))
+		auditAPIKey(r, "api_token.create", id, fmt.Sprintf("API key %q created for %s (expires %s)", ses.Name, p.User.Username, ses.ExpiresAt.UTC().Format(time.RFC3339)))
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"id":         id,
+			"name":       ses.Name,
+			"token":      ses.Token,
+			"expires_at": ses.ExpiresAt,
+		})
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// handleAPIKey serves GET /api/v1/api-keys/{id}/usage (daily and per-endpoint
+// usage over ?days=) and DELETE /api/v1/api-keys/{id}. Owners revoke their
+// own keys, tenant admins those of users they manage; admins revoke any.
+func handleAPIKey(w http.ResponseWriter, r *http.Request) {
+	rest := strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/v1/api-keys/"), "/")
+	id, sub, _ := strings.Cut(rest, "/")
+	if id == "" || (sub != "" && sub != "usage") {
+		http.NotFound(w, r)
+		return
+	}
+	ctx := r.Context()
+	now := time.Now().UTC()
+	p := getPrincipal(r)
+
+	switch {
+	case r.Method == http.MethodGet && sub == "usage":
+		if !authorizeOrReject(w, r, authz.ActionAPIKeysRead, authz.ResourceRef{}) {
+			return
+		}
+		if err := apiUsage.flush(ctx, serverStore); err != nil {
+			logWarn("Failed to save API key usage", "error", err)
+		}
+		key, err := findVisibleAPIKey(ctx, p, id, now)
+		if err != nil {
+			http.Error(w, "failed to load API key", http.StatusInternalServerError)
+			return
+		}
+		if key == nil {
+			http.Error(w, "API key not found", http.StatusNotFound)
+			return
+		}
+		days := apiKeyUsageDays(r)
+		usage, err := serverStore.ListAPITokenUsage(ctx, key.Token, now.AddDate(0, 0, -(days-1)))
+		if err != nil {
+			logError("Failed to load API key usage", "id", id, "error", err)
+			http.Error(w, "failed to load API key usage", http.StatusInternalServerError)
+			return
+		}
+		byDay := map[string]*apiKeyDayUsage{}
+		for _, u := range usage {
+			d := byDay[u.Day]
+			if d == nil {
+				d = &apiKeyDayUsage{Day: u.Day}
+				byDay[u.Day] = d
+			}
+			d.Requests += u.Requests
+			d.Errors += u.Errors
+		}
+		daily := make([]apiKeyDayUsage, 0, days)
+		for i := days - 1; i >= 0; i-- {
+			day := storage.APITokenUsageDay(now.AddDate(0, 0, -i))
+			if d := byDay[day]; d != nil {
+				daily = append(daily, *d)
+			} else {
+				daily = append(daily, apiKeyDayUsage{Day: day})
+			}
+		}
+		view := summarizeAPIKey(key, usage, now, 0)
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"key":       view,
+			"days":      days,
+			"daily":     daily,
+			"endpoints": view.TopEndpoints,
+		})
+	case r.Method == http.MethodDelete && sub == "":
+		if !authorizeOrReject(w, r, authz.ActionAPIKeysWrite, authz.ResourceRef{}) {
+			return
+		}
+		key, err := findVisibleAPIKey(ctx, p, id, now)
+		if err != nil {
+			http.Error(w, "failed to load API key", http.StatusInternalServerError)
+			return
+		}
+		if key == nil {
+			http.Error(w, "API key not found", http.StatusNotFound)
+			return
+		}
+		if !p.IsAdmin() && (p.User == nil || key.UserID != p.User.ID) {
+			owner, _ := serverStore.GetUserByID(ctx, key.UserID)
+			if !p.CanManageUser(owner) {
+				http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+				return
+			}
+		}
+		if err := serverStore.DeleteSessionByHash(ctx, key.Token); err != nil {
+			logError("Failed to revoke API key", "id", id, "error", err)
+			http.Error(w, "failed to revoke API key", http.StatusInternalServerError)
+			return
+		}
+		auditAPIKey(r, "api_token.revoke", id, fmt.Sprintf("API key %q of %s revoked", apiKeyLabel(key), key.Username))
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func auditAPIKey(r *http.Request, action, id, details string) {
+	actorType, actorID, actorName, actorTenant := auditActorFromPrincipal(r)
+	logAuditEntry(r.Context(), &storage.AuditEntry{
+		ActorType:  actorType,
+		ActorID:    actorID,
+		ActorName:  actorName,
+		TenantID:   actorTenant,
+		Action:     action,
+		TargetType: "api_token",
+		TargetID:   id,
+		Details:    details,
+		IPAddress:  extractClientIP(r),
+		UserAgent:  r.Header.Get("User-Agent"),
+	})
+}
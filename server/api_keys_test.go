@@ -0,0 +1,160 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"printmaster/server/storage"
+)
+
+func TestAPIUsageEndpoint(t *testing.T) {
+	cases := map[string]string{
+		"/api/v1/devices":                          "GET /api/v1/devices",
+		"/api/v1/devices/JPBCD12345/metrics":       "GET /api/v1/devices/{id}/metrics",
+		"/api/v1/alerts/42":                        "GET /api/v1/alerts/{id}",
+		"/api/v1/agents/0f8c2d9e-1b7a-4c3e-9d2f-1": "GET /api/v1/agents/{id}",
+	}
+	for path, want := range cases {
+		if got := apiUsageEndpoint(http.MethodGet, path); got != want {
+			t.Errorf("apiUsageEndpoint(%q) = %q, want %q", path, got, want)
+		}
+	}
+}
+
+func TestAPIKeysUsageAndVisibility(t *testing.T) {
+	store, _, _ := setupSessionTest(t, 30)
+	prevUsage := apiUsage
+	apiUsage = newAPIUsageTracker()
+	t.Cleanup(func() { apiUsage = prevUsage })
+	ctx := context.Background()
+
+	owner := &storage.User{Username: "integrator", Role: storage.RoleOperator, TenantID: "acme", Email: "integrator@example.com"}
+	colleague := &storage.User{Username: "acme-ops", Role: storage.RoleOperator, TenantID: "acme"}
+	outsider := &storage.User{Username: "other-ops", Role: storage.RoleOperator, TenantID: "globex"}
+	for _, u := range []*storage.User{owner, colleague, outsider} {
+		if err := store.CreateUser(ctx, u, "Password-123"); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	rr := httptest.NewRecorder()
+	handleAPIKeys(rr, InjectTestUser(httptest.NewRequest(http.MethodPost, "/api/v1/api-keys", strings.NewReader(`{"name":"ERP sync","ttl_days":30}`)), owner))
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("create key: %d %s", rr.Code, rr.Body.String())
+	}
+	var created struct {
+		ID    string `json:"id"`
+		Token string `json:"token"`
+	}
+	json.Unmarshal(rr.Body.Bytes(), &created)
+	if created.ID == "" || created.Token == "" {
+		t.Fatalf("unexpected create response: %s", rr.Body.String())
+	}
+
+	// Requests made with the key are counted per endpoint, errors included
+	api := requireWebAuth(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, "/missing") {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte("{}"))
+	})
+	for _, path := range []string{"/api/v1/devices", "/api/v1/devices", "/api/v1/devices/JPBCD12345/missing"} {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.Header.Set("Authorization", "Bearer "+created.Token)
+		api(httptest.NewRecorder(), req)
+	}
+	// Browser sessions are not counted
+	web, _ := store.CreateSession(ctx, owner.ID, 60)
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/devices", nil)
+	req.AddCookie(&http.Cookie{Name: "pm_session", Value: web.Token})
+	api(httptest.NewRecorder(), req)
+
+	list := func(u *storage.User) []apiKeyView {
+		t.Helper()
+		rr := httptest.NewRecorder()
+		handleAPIKeys(rr, InjectTestUser(httptest.NewRequest(http.MethodGet, "/api/v1/api-keys", nil), u))
+		if rr.Code != http.StatusOK {
+			t.Fatalf("list keys as %s: %d", u.Username, rr.Code)
+		}
+		var resp struct {
+			Keys []apiKeyView `json:"keys"`
+		}
+		json.Unmarshal(rr.Body.Bytes(), &resp)
+		return resp.Keys
+	}
+	keys := list(colleague)
+	if len(keys) != 1 || keys[0].ID != created.ID || keys[0].Name != "ERP sync" {
+		t.Fatalf("tenant operator should see the key: %+v", keys)
+	}
+	k := keys[0]
+	if k.Requests != 3 || k.Errors != 1 || k.LastUsedAt == nil || len(k.TopEndpoints) != 2 || k.TopEndpoints[0].Endpoint != "GET /api/v1/devices" {
+		t.Fatalf("unexpected usage summary: %+v", k)
+	}
+	if keys := list(outsider); len(keys) != 0 {
+		t.Fatalf("operator of another tenant sees %+v", keys)
+	}
+	rr = httptest.NewRecorder()
+	handleAPIKeys(rr, InjectTestUser(httptest.NewRequest(http.MethodGet, "/api/v1/api-keys", nil), NewTestUser(storage.RoleViewer, "acme")))
+	if rr.Code != http.StatusForbidden {
+		t.Fatalf("viewer listing keys: expected 403, got %d", rr.Code)
+	}
+
+	rr = httptest.NewRecorder()
+	handleAPIKey(rr, InjectTestUser(httptest.NewRequest(http.MethodGet, "/api/v1/api-keys/"+created.ID+"/usage?days=7", nil), owner))
+	var usage struct {
+		Daily     []apiKeyDayUsage      `json:"daily"`
+		Endpoints []apiKeyEndpointUsage `json:"endpoints"`
+	}
+	json.Unmarshal(rr.Body.Bytes(), &usage)
+	if rr.Code != http.StatusOK || len(usage.Daily) != 7 || usage.Daily[6].Requests != 3 || len(usage.Endpoints) != 2 {
+		t.Fatalf("usage: %d %s", rr.Code, rr.Body.String())
+	}
+
+	// API keys outlive the idle timeout that ends browser sessions
+	hash := storage.TokenHash(created.Token)
+	if err := store.TouchSession(ctx, hash, time.Now().Add(-2*time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := loadUserForSessionToken(created.Token); err != nil {
+		t.Fatalf("idle API key rejected: %v", err)
+	}
+
+	// Keys close to expiry are flagged and warned about once
+	if _, err := store.CreateAPIToken(ctx, owner.ID, "short", 24*60); err != nil {
+		t.Fatal(err)
+	}
+	warnExpiringAPIKeys(ctx, time.Now())
+	if left, _ := store.ListAPITokensExpiring(ctx, time.Now().Add(apiKeyExpiryWarning)); len(left) != 0 {
+		t.Fatalf("expiring key not marked as warned: %+v", left)
+	}
+	var short apiKeyView
+	for _, k := range list(owner) {
+		if k.Name == "short" {
+			short = k
+		}
+	}
+	if !short.ExpiringSoon {
+		t.Fatalf("short-lived key not flagged: %+v", short)
+	}
+
+	// Only the owner (or an admin) revokes a key
+	rr = httptest.NewRecorder()
+	handleAPIKey(rr, InjectTestUser(httptest.NewRequest(http.MethodDelete, "/api/v1/api-keys/"+created.ID, nil), colleague))
+	if rr.Code != http.StatusForbidden {
+		t.Fatalf("colleague revoking key: expected 403, got %d", rr.Code)
+	}
+	rr = httptest.NewRecorder()
+	handleAPIKey(rr, InjectTestUser(httptest.NewRequest(http.MethodDelete, "/api/v1/api-keys/"+created.ID, nil), owner))
+	if rr.Code != http.StatusNoContent {
+		t.Fatalf("owner revoking key: %d %s", rr.Code, rr.Body.String())
+	}
+	if _, err := store.GetSessionByToken(ctx, created.Token); err == nil {
+		t.Fatal("revoked key still valid")
+	}
+}
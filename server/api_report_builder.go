@@ -0,0 +1,119 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sort"
+
+	"printmaster/server/authz"
+	"printmaster/server/reports"
+	"printmaster/server/storage"
+)
+
+// handleReportBuilderFields describes what the report builder can select.
+// GET /api/v1/reports/builder/fields
+func handleReportBuilderFields(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	entities := make(map[string][]reports.BuilderField)
+	for _, entity := range reports.BuilderEntities() {
+		entities[entity] = reports.BuilderFields(entity)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"entities":   entities,
+		"filter_ops": reports.BuilderFilterOps,
+		"formats": []string{
+			storage.ReportFormatCSV,
+			storage.ReportFormatXLSX,
+			storage.ReportFormatPDF,
+			storage.ReportFormatJSON,
+			storage.ReportFormatHTML,
+		},
+	})
+}
+
+// prepareCustomReport authorizes a custom report save and pins it to the
+// caller's tenants. Custom reports are always saved per tenant for scoped
+// users; when no tenants are given, all of the caller's tenants are used.
+func prepareCustomReport(w http.ResponseWriter, r *http.Request, report *storage.ReportDefinition) bool {
+	if !authorizeOrReject(w, r, authz.ActionReportsBuild, authz.ResourceRef{TenantIDs: report.TenantIDs}) {
+		return false
+	}
+	scope, ok := tenantScope(getPrincipal(r))
+	if !ok {
+		http.Error(w, "forbidden", http.StatusForbidden)
+		return false
+	}
+	if scope != nil && len(report.TenantIDs) == 0 {
+		for id := range scope {
+			report.TenantIDs = append(report.TenantIDs, id)
+		}
+		sort.Strings(report.TenantIDs)
+	}
+	if report.Scope == "" {
+		report.Scope = storage.ReportScopeFleet
+		if len(report.TenantIDs) > 0 {
+			report.Scope = storage.ReportScopeTenant
+		}
+	}
+	if err := reports.ValidateCustomReport(report); err != nil {
+		http.Error(w, "invalid custom report: "+err.Error(), http.StatusBadRequest)
+		return false
+	}
+	return true
+}
+
+// reportVisible reports whether a caller with the given tenant scope may see
+// a report. Reports pinned to tenants, such as per-tenant scheduled reports,
+// are only visible when every tenant they cover is in scope; unpinned custom
+// reports are only visible to callers without a tenant scope.
+func reportVisible(scope map[string]struct{}, report *storage.ReportDefinition) bool {
+	if scope == nil || report == nil {
+		return true
+	}
+	if len(report.TenantIDs) == 0 {
+		return report.Type != storage.ReportTypeCustom
+	}
+	for _, id := range report.TenantIDs {
+		if !tenantAllowed(scope, id) {
+			return false
+		}
+	}
+	return true
+}
+
+// reportTenantScope returns the tenants whose custom reports the caller may
+// see. A nil result means all tenants; an empty one means none.
+func reportTenantScope(r *http.Request) map[string]struct{} {
+	scope, ok := tenantScope(getPrincipal(r))
+	if !ok {
+		return map[string]struct{}{}
+	}
+	return scope
+}
+
+// hiddenReports returns the IDs of reports the caller may not see, so their
+// schedules and runs can be left out of listings.
+func hiddenReports(ctx context.Context, r *http.Request) (map[int64]bool, error) {
+	scope := reportTenantScope(r)
+	if scope == nil {
+		return nil, nil
+	}
+	all, err := serverStore.ListReports(ctx, storage.ReportFilter{})
+	if err != nil {
+		return nil, err
+	}
+	hidden := make(map[int64]bool)
+	for _, report := range all {
+		if !reportVisible(scope, report) {
+			hidden[report.ID] = true
+		}
+	}
+	return hidden, nil
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"printmaster/server/storage"
+)
+
+func TestCustomReportsAreSavedPerTenant(t *testing.T) {
+	store := SetupTestStore(t)
+	ctx := context.Background()
+	operator := NewTestUser(storage.RoleOperator, "tenant-a")
+
+	create := func(user *storage.User, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/reports", bytes.NewBufferString(body))
+		req = InjectTestUser(req, user)
+		rr := httptest.NewRecorder()
+		handleReports(rr, req)
+		return rr
+	}
+
+	const body = `{"name":"Mono pages by model","type":"custom","format":"xlsx","columns":["model","mono_pages_in_period"],"group_by":["model"],"options_json":"{\"entity\":\"metrics\"}"}`
+	if rr := create(NewTestUser(storage.RoleViewer, "tenant-a"), body); rr.Code != http.StatusForbidden {
+		t.Fatalf("viewer should not build reports, got %d", rr.Code)
+	}
+	if rr := create(operator, `{"name":"bad","type":"custom","columns":["nope"],"options_json":"{\"entity\":\"devices\"}"}`); rr.Code != http.StatusBadRequest {
+		t.Fatalf("invalid column should be rejected, got %d", rr.Code)
+	}
+	if rr := create(operator, `{"name":"other","type":"custom","tenant_ids":["tenant-b"],"options_json":"{\"entity\":\"devices\"}"}`); rr.Code != http.StatusForbidden {
+		t.Fatalf("operator must not save reports for other tenants, got %d", rr.Code)
+	}
+
+	rr := create(operator, body)
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("create failed: %d %s", rr.Code, rr.Body.String())
+	}
+	var created storage.ReportDefinition
+	if err := json.NewDecoder(rr.Body).Decode(&created); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if len(created.TenantIDs) != 1 || created.TenantIDs[0] != "tenant-a" || created.Scope != storage.ReportScopeTenant {
+		t.Fatalf("report not pinned to operator tenant: %+v", created)
+	}
+
+	other := &storage.ReportDefinition{Name: "tenant b", Type: storage.ReportTypeCustom, Format: storage.ReportFormatCSV, TenantIDs: []string{"tenant-b"}}
+	if err := store.CreateReport(ctx, other); err != nil {
+		t.Fatalf("CreateReport: %v", err)
+	}
+
+	req := InjectTestUser(httptest.NewRequest(http.MethodGet, "/api/v1/reports?type=custom", nil), operator)
+	list := httptest.NewRecorder()
+	handleReports(list, req)
+	var resp struct {
+		Reports []*storage.ReportDefinition `json:"reports"`
+	}
+	if err := json.NewDecoder(list.Body).Decode(&resp); err != nil {
+		t.Fatalf("decode list: %v", err)
+	}
+	if len(resp.Reports) != 1 || resp.Reports[0].ID != created.ID {
+		t.Fatalf("operator should only see their tenant's custom reports, got %+v", resp.Reports)
+	}
+
+	req = InjectTestUser(httptest.NewRequest(http.MethodPost, "/api/v1/reports/"+strconv.FormatInt(other.ID, 10)+"/run", nil), operator)
+	run := httptest.NewRecorder()
+	handleReport(run, req)
+	if run.Code != http.StatusNotFound {
+		t.Fatalf("running another tenant's report should 404, got %d", run.Code)
+	}
+}
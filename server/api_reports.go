@@ -5,8 +5,10 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"printmaster/server/authz"
 	"printmaster/server/reports"
 	"printmaster/server/storage"
+	"slices"
 	"strconv"
 	"strings"
 	"time"
@@ -18,7 +20,11 @@ type ReportStore struct {
 }
 
 func (rs *ReportStore) ListAllDevices(ctx context.Context) ([]*storage.Device, error) {
-	return rs.store.ListAllDevices(ctx)
+	devices, err := rs.store.ListAllDevices(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return filterApprovedDevices(ctx, rs.store, devices), nil
 }
 
 func (rs *ReportStore) GetLatestMetrics(ctx context.Context, serial string) (*storage.MetricsSnapshot, error) {
@@ -61,6 +67,18 @@ func (rs *ReportStore) GetAlertSummary(ctx context.Context) (*storage.AlertSumma
 	return rs.store.GetAlertSummary(ctx)
 }
 
+func (rs *ReportStore) ListYieldBaselines(ctx context.Context) ([]storage.YieldBaseline, error) {
+	return rs.store.ListYieldBaselines(ctx)
+}
+
+func (rs *ReportStore) ListDeviceNotes(ctx context.Context, filter storage.DeviceNoteFilter) ([]*storage.DeviceNote, error) {
+	return rs.store.ListDeviceNotes(ctx, filter)
+}
+
+func (rs *ReportStore) ListParseSamples(ctx context.Context, filter storage.ParseSampleFilter) ([]*storage.ParseSample, error) {
+	return rs.store.ListParseSamples(ctx, filter)
+}
+
 func (rs *ReportStore) GetReport(ctx context.Context, id int64) (*storage.ReportDefinition, error) {
 	return rs.store.GetReport(ctx, id)
 }
@@ -120,6 +138,15 @@ func handleReports(w http.ResponseWriter, r *http.Request) {
 			http.Error(w, fmt.Sprintf("list reports: %v", err), http.StatusInternalServerError)
 			return
 		}
+		if scope := reportTenantScope(r); scope != nil {
+			visible := reports[:0]
+			for _, report := range reports {
+				if reportVisible(scope, report) {
+					visible = append(visible, report)
+				}
+			}
+			reports = visible
+		}
 
 		w.Header().Set("Content-Type", "application/json")
 		json.NewEncoder(w).Encode(map[string]interface{}{
@@ -135,6 +162,34 @@ func handleReports(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 
+		if report.Type == storage.ReportTypeCustom && !prepareCustomReport(w, r, &report) {
+			return
+		}
+		if report.Type == storage.ReportTypeCapacityPlanning {
+			if _, err := reports.ParseCapacityOptions(report.OptionsJSON); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+		}
+		if report.Type == storage.ReportTypeTonerYield {
+			if _, err := reports.ParseYieldOptions(report.OptionsJSON); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+		}
+		if report.Type == storage.ReportTypeSustainability {
+			if _, err := reports.ParseSustainabilityOptions(report.OptionsJSON); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+		}
+		if report.Type == storage.ReportTypeAvailability {
+			if _, err := reports.ParseAvailabilityOptions(report.OptionsJSON); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+		}
+
 		// Get current user for created_by
 		if principal := getPrincipal(r); principal != nil {
 			report.CreatedBy = principal.User.Username
@@ -171,6 +226,9 @@ func handleReport(w http.ResponseWriter, r *http.Request) {
 			http.Error(w, "Invalid report ID", http.StatusBadRequest)
 			return
 		}
+		if !reportAccessible(w, r, id) {
+			return
+		}
 
 		switch {
 		case strings.HasPrefix(subPath, "/run"):
@@ -191,21 +249,21 @@ func handleReport(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	existing, err := serverStore.GetReport(ctx, id)
+	if err != nil {
+		serverLogger.Error("Failed to get report", "report_id", id, "error", err)
+		http.Error(w, fmt.Sprintf("get report: %v", err), http.StatusInternalServerError)
+		return
+	}
+	if existing == nil || !reportVisible(reportTenantScope(r), existing) {
+		http.Error(w, "Report not found", http.StatusNotFound)
+		return
+	}
+
 	switch r.Method {
 	case http.MethodGet:
-		report, err := serverStore.GetReport(ctx, id)
-		if err != nil {
-			serverLogger.Error("Failed to get report", "report_id", id, "error", err)
-			http.Error(w, fmt.Sprintf("get report: %v", err), http.StatusInternalServerError)
-			return
-		}
-		if report == nil {
-			http.Error(w, "Report not found", http.StatusNotFound)
-			return
-		}
-
 		w.Header().Set("Content-Type", "application/json")
-		json.NewEncoder(w).Encode(report)
+		json.NewEncoder(w).Encode(existing)
 
 	case http.MethodPut:
 		var report storage.ReportDefinition
@@ -214,6 +272,38 @@ func handleReport(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 		report.ID = id
+		if existing.Type == storage.ReportTypeCustom || report.Type == storage.ReportTypeCustom {
+			if !authorizeOrReject(w, r, authz.ActionReportsBuild, authz.ResourceRef{TenantIDs: existing.TenantIDs}) {
+				return
+			}
+			if report.Type == storage.ReportTypeCustom && !prepareCustomReport(w, r, &report) {
+				return
+			}
+		}
+		if report.Type == storage.ReportTypeCapacityPlanning {
+			if _, err := reports.ParseCapacityOptions(report.OptionsJSON); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+		}
+		if report.Type == storage.ReportTypeTonerYield {
+			if _, err := reports.ParseYieldOptions(report.OptionsJSON); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+		}
+		if report.Type == storage.ReportTypeSustainability {
+			if _, err := reports.ParseSustainabilityOptions(report.OptionsJSON); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+		}
+		if report.Type == storage.ReportTypeAvailability {
+			if _, err := reports.ParseAvailabilityOptions(report.OptionsJSON); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+		}
 
 		if err := serverStore.UpdateReport(ctx, &report); err != nil {
 			serverLogger.Error("Failed to update report", "report_id", report.ID, "error", err)
@@ -225,6 +315,10 @@ func handleReport(w http.ResponseWriter, r *http.Request) {
 		json.NewEncoder(w).Encode(report)
 
 	case http.MethodDelete:
+		if existing.Type == storage.ReportTypeCustom &&
+			!authorizeOrReject(w, r, authz.ActionReportsBuild, authz.ResourceRef{TenantIDs: existing.TenantIDs}) {
+			return
+		}
 		if err := serverStore.DeleteReport(ctx, id); err != nil {
 			serverLogger.Error("Failed to delete report", "report_id", id, "error", err)
 			http.Error(w, fmt.Sprintf("delete report: %v", err), http.StatusInternalServerError)
@@ -237,6 +331,22 @@ func handleReport(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// reportAccessible rejects requests for reports that do not exist or that the
+// caller may not see.
+func reportAccessible(w http.ResponseWriter, r *http.Request, id int64) bool {
+	report, err := serverStore.GetReport(r.Context(), id)
+	if err != nil {
+		serverLogger.Error("Failed to get report", "report_id", id, "error", err)
+		http.Error(w, fmt.Sprintf("get report: %v", err), http.StatusInternalServerError)
+		return false
+	}
+	if report == nil || !reportVisible(reportTenantScope(r), report) {
+		http.Error(w, "Report not found", http.StatusNotFound)
+		return false
+	}
+	return true
+}
+
 // handleReportRun handles POST /api/v1/reports/{id}/run
 func handleReportRun(w http.ResponseWriter, r *http.Request, reportID int64) {
 	ctx := r.Context()
@@ -337,6 +447,21 @@ func handleReportRunsCollection(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, fmt.Sprintf("list runs: %v", err), http.StatusInternalServerError)
 		return
 	}
+	hidden, err := hiddenReports(ctx, r)
+	if err != nil {
+		serverLogger.Error("Failed to list custom reports", "error", err)
+		http.Error(w, fmt.Sprintf("list runs: %v", err), http.StatusInternalServerError)
+		return
+	}
+	if len(hidden) > 0 {
+		visible := runs[:0]
+		for _, run := range runs {
+			if !hidden[run.ReportID] {
+				visible = append(visible, run)
+			}
+		}
+		runs = visible
+	}
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]interface{}{
@@ -392,57 +517,306 @@ func handleReportSchedules(w http.ResponseWriter, r *http.Request, reportID int6
 	}
 }
 
-// handleReportSchedulesCollection handles GET /api/v1/report-schedules (list all schedules)
+// scheduleView is a schedule together with the report it runs, as listed by
+// /api/v1/report-schedules.
+type scheduleView struct {
+	*storage.ReportSchedule
+	ReportName      string   `json:"report_name,omitempty"`
+	ReportType      string   `json:"report_type,omitempty"`
+	Format          string   `json:"output_format,omitempty"`
+	TimeRangeType   string   `json:"time_range,omitempty"`
+	TenantIDs       []string `json:"tenant_ids,omitempty"`
+	EmailRecipients []string `json:"email_recipients,omitempty"`
+}
+
+// scheduleRequest is the body of POST /api/v1/report-schedules, which creates
+// a report and its schedule in one step.
+type scheduleRequest struct {
+	Name            string   `json:"name"`
+	ReportType      string   `json:"report_type"`
+	Format          string   `json:"output_format"`
+	TimeRangeType   string   `json:"time_range"`
+	OptionsJSON     string   `json:"options_json"`
+	TenantIDs       []string `json:"tenant_ids"`
+	EmailRecipients []string `json:"email_recipients"`
+	Frequency       string   `json:"frequency"`
+	DayOfWeek       int      `json:"day_of_week"`
+	DayOfMonth      int      `json:"day_of_month"`
+	TimeOfDay       string   `json:"time_of_day"`
+	Timezone        string   `json:"timezone"`
+	Enabled         *bool    `json:"enabled"`
+}
+
+// handleReportSchedulesCollection handles GET/POST /api/v1/report-schedules
 func handleReportSchedulesCollection(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 
-	if r.Method != http.MethodGet {
+	switch r.Method {
+	case http.MethodGet:
+		// List all schedules (no report filter)
+		schedules, err := serverStore.ListReportSchedules(ctx, 0)
+		if err != nil {
+			serverLogger.Error("Failed to list all report schedules", "error", err)
+			http.Error(w, fmt.Sprintf("list schedules: %v", err), http.StatusInternalServerError)
+			return
+		}
+		all, err := serverStore.ListReports(ctx, storage.ReportFilter{})
+		if err != nil {
+			serverLogger.Error("Failed to list reports", "error", err)
+			http.Error(w, fmt.Sprintf("list schedules: %v", err), http.StatusInternalServerError)
+			return
+		}
+		byID := make(map[int64]*storage.ReportDefinition, len(all))
+		for _, report := range all {
+			byID[report.ID] = report
+		}
+		scope := reportTenantScope(r)
+		views := make([]scheduleView, 0, len(schedules))
+		for _, schedule := range schedules {
+			report := byID[schedule.ReportID]
+			if report != nil && !reportVisible(scope, report) {
+				continue
+			}
+			view := scheduleView{ReportSchedule: schedule}
+			if report != nil {
+				view.ReportName = report.Name
+				view.ReportType = report.Type
+				view.Format = report.Format
+				view.TimeRangeType = report.TimeRangeType
+				view.TenantIDs = report.TenantIDs
+				view.EmailRecipients = report.EmailRecipients
+			}
+			views = append(views, view)
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"schedules": views,
+			"count":     len(views),
+		})
+
+	case http.MethodPost:
+		var req scheduleRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, fmt.Sprintf("invalid request: %v", err), http.StatusBadRequest)
+			return
+		}
+		report, schedule, ok := prepareScheduledReport(w, r, &req)
+		if !ok {
+			return
+		}
+		if principal := getPrincipal(r); principal != nil {
+			report.CreatedBy = principal.User.Username
+		}
+
+		if err := serverStore.CreateReport(ctx, report); err != nil {
+			serverLogger.Error("Failed to create report", "name", report.Name, "type", report.Type, "error", err)
+			http.Error(w, fmt.Sprintf("create report: %v", err), http.StatusInternalServerError)
+			return
+		}
+		schedule.ReportID = report.ID
+		if err := serverStore.CreateReportSchedule(ctx, schedule); err != nil {
+			serverLogger.Error("Failed to create report schedule", "report_id", report.ID, "error", err)
+			// Don't leave a report behind that nothing runs
+			_ = serverStore.DeleteReport(ctx, report.ID)
+			http.Error(w, fmt.Sprintf("create schedule: %v", err), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(scheduleView{
+			ReportSchedule:  schedule,
+			ReportName:      report.Name,
+			ReportType:      report.Type,
+			Format:          report.Format,
+			TimeRangeType:   report.TimeRangeType,
+			TenantIDs:       report.TenantIDs,
+			EmailRecipients: report.EmailRecipients,
+		})
+
+	default:
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
-		return
 	}
+}
 
-	// List all schedules (no report filter)
-	schedules, err := serverStore.ListReportSchedules(ctx, 0)
+// prepareScheduledReport authorizes and validates a one-step schedule request
+// and builds the report definition and schedule it describes. Tenant-scoped
+// callers may only schedule reports for their own tenants and get them pinned
+// to their scope when they name none. It writes the error response when the
+// request is rejected.
+func prepareScheduledReport(w http.ResponseWriter, r *http.Request, req *scheduleRequest) (*storage.ReportDefinition, *storage.ReportSchedule, bool) {
+	req.Name = strings.TrimSpace(req.Name)
+	if req.Name == "" {
+		http.Error(w, "name is required", http.StatusBadRequest)
+		return nil, nil, false
+	}
+	if !slices.Contains(storage.GetBuiltInReportTypes(), storage.ReportType(req.ReportType)) {
+		http.Error(w, fmt.Sprintf("unsupported report type %q", req.ReportType), http.StatusBadRequest)
+		return nil, nil, false
+	}
+	if req.Format == "" {
+		req.Format = storage.ReportFormatCSV
+	}
+	switch req.Format {
+	case storage.ReportFormatCSV, storage.ReportFormatJSON, storage.ReportFormatPDF, storage.ReportFormatXLSX:
+	default:
+		http.Error(w, fmt.Sprintf("unsupported format %q", req.Format), http.StatusBadRequest)
+		return nil, nil, false
+	}
+	switch req.Frequency {
+	case storage.ScheduleFrequencyDaily, storage.ScheduleFrequencyWeekly, storage.ScheduleFrequencyMonthly:
+	default:
+		http.Error(w, "frequency must be daily, weekly or monthly", http.StatusBadRequest)
+		return nil, nil, false
+	}
+	if req.Frequency == storage.ScheduleFrequencyMonthly && (req.DayOfMonth < 1 || req.DayOfMonth > 28) {
+		req.DayOfMonth = 1
+	}
+	if req.TimeOfDay == "" {
+		req.TimeOfDay = "08:00"
+	}
+	if req.TimeRangeType == "" {
+		req.TimeRangeType = "last_30d"
+	}
+
+	var recipients []string
+	for _, addr := range req.EmailRecipients {
+		for _, part := range strings.Split(addr, ",") {
+			if part = strings.TrimSpace(part); part == "" {
+				continue
+			}
+			if _, err := validateEmailAddress(part); err != nil {
+				http.Error(w, fmt.Sprintf("invalid email recipient %q", part), http.StatusBadRequest)
+				return nil, nil, false
+			}
+			recipients = append(recipients, part)
+		}
+	}
+
+	// Scheduled reports email fleet data out, so they need the same role as
+	// the report builder
+	if !authorizeOrReject(w, r, authz.ActionReportsBuild, authz.ResourceRef{TenantIDs: req.TenantIDs}) {
+		return nil, nil, false
+	}
+	tenantIDs := req.TenantIDs
+	scope, ok := tenantScope(getPrincipal(r))
+	if !ok {
+		http.Error(w, "forbidden", http.StatusForbidden)
+		return nil, nil, false
+	}
+	if scope != nil && len(tenantIDs) == 0 {
+		for id := range scope {
+			tenantIDs = append(tenantIDs, id)
+		}
+		slices.Sort(tenantIDs)
+	}
+	for _, id := range tenantIDs {
+		if !tenantAllowed(scope, id) {
+			http.Error(w, "tenant not allowed", http.StatusForbidden)
+			return nil, nil, false
+		}
+	}
+
+	report := &storage.ReportDefinition{
+		Name:            req.Name,
+		Type:            req.ReportType,
+		Format:          req.Format,
+		Scope:           storage.ReportScopeFleet,
+		TenantIDs:       tenantIDs,
+		TimeRangeType:   req.TimeRangeType,
+		OptionsJSON:     req.OptionsJSON,
+		EmailRecipients: recipients,
+	}
+	if len(tenantIDs) > 0 {
+		report.Scope = storage.ReportScopeTenant
+	}
+	var err error
+	switch report.Type {
+	case storage.ReportTypeCapacityPlanning:
+		_, err = reports.ParseCapacityOptions(report.OptionsJSON)
+	case storage.ReportTypeTonerYield:
+		_, err = reports.ParseYieldOptions(report.OptionsJSON)
+	case storage.ReportTypeSustainability:
+		_, err = reports.ParseSustainabilityOptions(report.OptionsJSON)
+	case storage.ReportTypeAvailability:
+		_, err = reports.ParseAvailabilityOptions(report.OptionsJSON)
+	}
 	if err != nil {
-		serverLogger.Error("Failed to list all report schedules", "error", err)
-		http.Error(w, fmt.Sprintf("list schedules: %v", err), http.StatusInternalServerError)
-		return
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return nil, nil, false
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(map[string]interface{}{
-		"schedules": schedules,
-		"count":     len(schedules),
-	})
+	schedule := &storage.ReportSchedule{
+		Name:       req.Name,
+		Enabled:    req.Enabled == nil || *req.Enabled,
+		Frequency:  req.Frequency,
+		DayOfWeek:  req.DayOfWeek,
+		DayOfMonth: req.DayOfMonth,
+		TimeOfDay:  req.TimeOfDay,
+		Timezone:   req.Timezone,
+	}
+	schedule.NextRunAt = calculateInitialNextRun(schedule)
+	return report, schedule, true
 }
 
-// handleSchedule handles GET/PUT/DELETE /api/v1/report-schedules/{id}
+// handleSchedule handles GET/PUT/DELETE /api/v1/report-schedules/{id} and
+// POST /api/v1/report-schedules/{id}/run
 func handleSchedule(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 
 	// Extract ID
 	idStr := strings.TrimPrefix(r.URL.Path, "/api/v1/report-schedules/")
+	runNow := false
+	if strings.HasSuffix(idStr, "/run") {
+		runNow = true
+		idStr = strings.TrimSuffix(idStr, "/run")
+	}
 	id, err := strconv.ParseInt(idStr, 10, 64)
 	if err != nil {
 		http.Error(w, "Invalid schedule ID", http.StatusBadRequest)
 		return
 	}
 
-	switch r.Method {
-	case http.MethodGet:
-		schedule, err := serverStore.GetReportSchedule(ctx, id)
-		if err != nil {
-			serverLogger.Error("Failed to get report schedule", "schedule_id", id, "error", err)
-			http.Error(w, fmt.Sprintf("get schedule: %v", err), http.StatusInternalServerError)
+	existing, err := serverStore.GetReportSchedule(ctx, id)
+	if err != nil {
+		serverLogger.Error("Failed to get report schedule", "schedule_id", id, "error", err)
+		http.Error(w, fmt.Sprintf("get schedule: %v", err), http.StatusInternalServerError)
+		return
+	}
+	if existing == nil {
+		http.Error(w, "Schedule not found", http.StatusNotFound)
+		return
+	}
+	if hidden, err := hiddenReports(ctx, r); err != nil || hidden[existing.ReportID] {
+		http.Error(w, "Schedule not found", http.StatusNotFound)
+		return
+	}
+
+	if runNow {
+		if r.Method != http.MethodPost {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 			return
 		}
-		if schedule == nil {
-			http.Error(w, "Schedule not found", http.StatusNotFound)
+		scheduler := reportScheduler
+		if scheduler == nil {
+			scheduler = newReportScheduler()
+		}
+		run, err := scheduler.RunSchedule(ctx, existing)
+		if err != nil {
+			serverLogger.Error("Scheduled report run failed", "schedule_id", id, "error", err)
+			http.Error(w, fmt.Sprintf("run schedule: %v", err), http.StatusInternalServerError)
 			return
 		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(run)
+		return
+	}
 
+	switch r.Method {
+	case http.MethodGet:
 		w.Header().Set("Content-Type", "application/json")
-		json.NewEncoder(w).Encode(schedule)
+		json.NewEncoder(w).Encode(existing)
 
 	case http.MethodPut:
 		var schedule storage.ReportSchedule
@@ -451,6 +825,7 @@ func handleSchedule(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 		schedule.ID = id
+		schedule.ReportID = existing.ReportID
 
 		// Recalculate next run if schedule changed
 		if schedule.NextRunAt.IsZero() {
@@ -513,30 +888,26 @@ func handleReportRunResult(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "Run not found", http.StatusNotFound)
 		return
 	}
+	if hidden, err := hiddenReports(ctx, r); err != nil || hidden[run.ReportID] {
+		http.Error(w, "Run not found", http.StatusNotFound)
+		return
+	}
 
 	if download && run.ResultData != "" {
-		// Serve the result as a file download
-		var contentType, ext string
-		switch run.Format {
-		case storage.ReportFormatJSON:
-			contentType = "application/json"
-			ext = "json"
-		case storage.ReportFormatCSV:
-			contentType = "text/csv"
-			ext = "csv"
-		case storage.ReportFormatHTML:
-			contentType = "text/html"
-			ext = "html"
-		default:
-			contentType = "application/octet-stream"
-			ext = "txt"
+		data, err := reports.DecodeResultData(run.Format, run.ResultData)
+		if err != nil {
+			serverLogger.Error("Failed to decode report run", "run_id", id, "error", err)
+			http.Error(w, "report data is corrupt", http.StatusInternalServerError)
+			return
 		}
 
-		filename := fmt.Sprintf("report_%d_%s.%s", run.ReportID, run.StartedAt.Format("20060102_150405"), ext)
+		// Serve the result as a file download
+		contentType, _ := reportFileType(run.Format)
+		filename := reportRunFilename(run)
 
 		w.Header().Set("Content-Type", contentType)
 		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
-		w.Write([]byte(run.ResultData))
+		w.Write(data)
 		return
 	}
 
@@ -257,6 +257,39 @@ func getRealIP(r *http.Request) string {
 	return remoteIP
 }
 
+// getTrustedHopIP returns the client address as vouched for by the server's
+// own proxies: the connection's address, or, when that is a trusted proxy, the
+// rightmost X-Forwarded-For hop that is not itself a trusted proxy. Entries to
+// the left of it were written by the client and are ignored, so unlike
+// getRealIP this is safe for IP allowlists.
+func getTrustedHopIP(r *http.Request) string {
+	remoteIP := extractIPFromAddr(r.RemoteAddr)
+	if serverConfig == nil || (!serverConfig.Server.BehindProxy && !serverConfig.Server.CloudflareProxy) {
+		return remoteIP
+	}
+	if !isTrustedProxy(remoteIP) {
+		return remoteIP
+	}
+
+	var hops []string
+	for _, header := range r.Header.Values("X-Forwarded-For") {
+		hops = append(hops, strings.Split(header, ",")...)
+	}
+	hop := remoteIP
+	for i := len(hops) - 1; i >= 0; i-- {
+		ip := strings.TrimSpace(hops[i])
+		if net.ParseIP(ip) == nil {
+			// Anything left of a malformed entry cannot be attributed
+			break
+		}
+		hop = ip
+		if !isTrustedProxy(ip) {
+			break
+		}
+	}
+	return hop
+}
+
 // defaultTrustedProxyCIDRs contains private network ranges commonly used by Docker/reverse proxies
 var defaultTrustedProxyCIDRs = []string{
 	"10.0.0.0/8",
@@ -39,7 +39,19 @@ const (
 	ActionAgentsWrite  Action = "agents.write"
 	ActionAgentsDelete Action = "agents.delete"
 
-	ActionDevicesRead Action = "devices.read"
+	// Forcing agent token rotation (suspected leak) - admin only
+	ActionAgentTokensRotate Action = "agent_tokens.rotate"
+
+	ActionDevicesRead    Action = "devices.read"
+	ActionDevicesApprove Action = "devices.approve"
+
+	// Device notes and maintenance log - tenant-scoped, operators+ write
+	ActionDeviceNotesRead  Action = "device_notes.read"
+	ActionDeviceNotesWrite Action = "device_notes.write"
+
+	// Incidents - tenant-scoped, operators+ work them
+	ActionIncidentsRead  Action = "incidents.read"
+	ActionIncidentsWrite Action = "incidents.write"
 
 	ActionMetricsSummaryRead Action = "metrics.summary.read"
 	ActionMetricsHistoryRead Action = "metrics.history.read"
@@ -56,15 +68,50 @@ const (
 	ActionSettingsFleetRead  Action = "settings.fleet.read"
 	ActionSettingsFleetWrite Action = "settings.fleet.write"
 
+	// SNMP community sweep lists (authorized onboarding) - admin only
+	ActionSettingsSNMPSweepWrite Action = "settings.snmp_sweep.write"
+
 	// Alert settings (rules, channels, policies) - tenant-scoped for operators+
 	ActionSettingsAlertsRead  Action = "settings.alerts.read"
 	ActionSettingsAlertsWrite Action = "settings.alerts.write"
 
+	// Feature flags (per-tenant entitlements) - admin-only writes
+	ActionFeatureFlagsRead  Action = "feature_flags.read"
+	ActionFeatureFlagsWrite Action = "feature_flags.write"
+
 	ActionLogsRead      Action = "logs.read"
 	ActionAuditLogsRead Action = "audit.logs.read"
 
 	ActionReleasesRead  Action = "releases.read"
 	ActionReleasesWrite Action = "releases.write"
+
+	// Report builder (custom reports) - tenant-scoped for operators+
+	ActionReportsBuild Action = "reports.build"
+
+	// MSP billing export (admin only)
+	ActionBillingRead  Action = "billing.read"
+	ActionBillingWrite Action = "billing.write"
+
+	// Read-only share links to tenant snapshots - tenant-scoped for operators+
+	ActionShareLinksRead  Action = "share_links.read"
+	ActionShareLinksWrite Action = "share_links.write"
+
+	// Third-party history imports (PaperCut/SafeQ) - admin only
+	ActionImportsWrite Action = "imports.write"
+
+	// Vendor detection rules (fleet-wide) - admin-only writes
+	ActionDetectionRulesRead  Action = "detection_rules.read"
+	ActionDetectionRulesWrite Action = "detection_rules.write"
+
+	// Four-eyes approvals for dangerous operations - operators see their
+	// tenants' requests, only admins decide
+	ActionActionApprovalsRead   Action = "action_approvals.read"
+	ActionActionApprovalsDecide Action = "action_approvals.decide"
+
+	// API keys (long-lived Bearer tokens) - operators manage their own keys
+	// and see usage of their tenants' keys
+	ActionAPIKeysRead  Action = "api_keys.read"
+	ActionAPIKeysWrite Action = "api_keys.write"
 )
 
 // ResourceRef carries contextual identifiers relevant for authorization checks.
@@ -106,6 +153,38 @@ func Authorize(subject Subject, action Action, resource ResourceRef) error {
 
 var rolePolicies = map[storage.Role][]string{
 	storage.RoleAdmin: {"*"},
+	storage.RoleTenantAdmin: {
+		// Everything operators may do, plus managing their tenants
+		"config.read",
+		"events.subscribe",
+		"ui.websocket.connect",
+		"agents.*",
+		"packages.generate",
+		"devices.read",
+		"devices.approve",
+		"device_notes.*",
+		"incidents.*",
+		"metrics.summary.read",
+		"metrics.history.read",
+		"proxy.agent",
+		"proxy.device",
+		"logs.read",
+		// Granular settings permissions (tenant-scoped via ResourceRef)
+		"settings.fleet.read",   // Read fleet settings (discovery, snmp, features)
+		"settings.fleet.write",  // Write fleet settings
+		"settings.alerts.read",  // Read alert rules/channels
+		"settings.alerts.write", // Write alert rules/channels
+		"feature_flags.read",    // See which features are enabled
+		"detection_rules.read",  // See vendor detection rules
+		"reports.build",         // Save custom reports for their tenants
+		"share_links.*",         // Share snapshots of their tenants' data
+		"action_approvals.read", // Track dangerous actions awaiting approval
+		"api_keys.*",            // Integration keys and their usage analytics
+		"users.*",               // Users whose tenants all fall within their own
+		"join_tokens.*",         // Agent onboarding tokens for their tenants
+		"tenants.read",          // Their own tenants and sites
+		"audit.logs.read",       // Audit entries of their tenants
+	},
 	storage.RoleOperator: {
 		"config.read",
 		"events.subscribe",
@@ -113,6 +192,9 @@ var rolePolicies = map[storage.Role][]string{
 		"agents.*",
 		"packages.generate",
 		"devices.read",
+		"devices.approve",
+		"device_notes.*",
+		"incidents.*",
 		"metrics.summary.read",
 		"metrics.history.read",
 		"proxy.agent",
@@ -123,6 +205,32 @@ var rolePolicies = map[storage.Role][]string{
 		"settings.fleet.write",  // Write fleet settings
 		"settings.alerts.read",  // Read alert rules/channels
 		"settings.alerts.write", // Write alert rules/channels
+		"feature_flags.read",    // See which features are enabled
+		"detection_rules.read",  // See vendor detection rules
+		"reports.build",         // Save custom reports for their tenants
+		"share_links.*",         // Share snapshots of their tenants' data
+		"action_approvals.read", // Track dangerous actions awaiting approval
+		"api_keys.*",            // Integration keys and their usage analytics
+	},
+	storage.RoleAuditor: {
+		"config.read",
+		"events.subscribe",
+		"ui.websocket.connect",
+		"agents.read",
+		"devices.read",
+		"device_notes.read",
+		"incidents.read",
+		"metrics.summary.read",
+		"metrics.history.read",
+		"logs.read",
+		"settings.fleet.read",
+		"settings.alerts.read",
+		"feature_flags.read",
+		"detection_rules.read",
+		"audit.logs.read",       // Audit entries of their tenants
+		"action_approvals.read", // Dangerous actions and who decided them
+		"share_links.read",      // Snapshots shared out of their tenants
+		"api_keys.read",         // Integration keys and their usage
 	},
 	storage.RoleViewer: {
 		"config.read",
@@ -130,12 +238,16 @@ var rolePolicies = map[storage.Role][]string{
 		"ui.websocket.connect",
 		"agents.read",
 		"devices.read",
+		"device_notes.read",
+		"incidents.read",
 		"metrics.summary.read",
 		"metrics.history.read",
 		"logs.read",
 		// Granular settings permissions (read-only, tenant-scoped)
 		"settings.fleet.read",  // Read fleet settings
 		"settings.alerts.read", // Read alert rules
+		"feature_flags.read",   // See which features are enabled
+		"detection_rules.read", // See vendor detection rules
 	},
 }
 
@@ -58,6 +58,146 @@ func TestAuthorizeRolePolicies(t *testing.T) {
 			resource: ResourceRef{TenantIDs: []string{"tenant-b"}},
 			wantErr:  ErrForbidden,
 		},
+		{
+			name: "operator denied billing export",
+			subject: Subject{
+				Role:             storage.RoleOperator,
+				AllowedTenantIDs: []string{"tenant-a"},
+			},
+			action:   ActionBillingRead,
+			resource: ResourceRef{TenantIDs: []string{"tenant-a"}},
+			wantErr:  ErrForbidden,
+		},
+		{
+			name: "operator allowed to share in-scope tenant",
+			subject: Subject{
+				Role:             storage.RoleOperator,
+				AllowedTenantIDs: []string{"tenant-a"},
+			},
+			action:   ActionShareLinksWrite,
+			resource: ResourceRef{TenantIDs: []string{"tenant-a"}},
+			wantErr:  nil,
+		},
+		{
+			name: "operator denied sharing other tenant",
+			subject: Subject{
+				Role:             storage.RoleOperator,
+				AllowedTenantIDs: []string{"tenant-a"},
+			},
+			action:   ActionShareLinksWrite,
+			resource: ResourceRef{TenantIDs: []string{"tenant-b"}},
+			wantErr:  ErrForbidden,
+		},
+		{
+			name: "operator denied history import",
+			subject: Subject{
+				Role:             storage.RoleOperator,
+				AllowedTenantIDs: []string{"tenant-a"},
+			},
+			action:   ActionImportsWrite,
+			resource: ResourceRef{TenantIDs: []string{"tenant-a"}},
+			wantErr:  ErrForbidden,
+		},
+		{
+			name: "viewer denied writing device notes",
+			subject: Subject{
+				Role:             storage.RoleViewer,
+				AllowedTenantIDs: []string{"tenant-a"},
+			},
+			action:   ActionDeviceNotesWrite,
+			resource: ResourceRef{TenantIDs: []string{"tenant-a"}},
+			wantErr:  ErrForbidden,
+		},
+		{
+			name: "viewer denied working incidents",
+			subject: Subject{
+				Role:             storage.RoleViewer,
+				AllowedTenantIDs: []string{"tenant-a"},
+			},
+			action:   ActionIncidentsWrite,
+			resource: ResourceRef{TenantIDs: []string{"tenant-a"}},
+			wantErr:  ErrForbidden,
+		},
+		{
+			name: "viewer denied share links",
+			subject: Subject{
+				Role:             storage.RoleViewer,
+				AllowedTenantIDs: []string{"tenant-a"},
+			},
+			action:   ActionShareLinksRead,
+			resource: ResourceRef{TenantIDs: []string{"tenant-a"}},
+			wantErr:  ErrForbidden,
+		},
+		{
+			name: "tenant admin allowed to manage in-scope users",
+			subject: Subject{
+				Role:             storage.RoleTenantAdmin,
+				AllowedTenantIDs: []string{"tenant-a"},
+			},
+			action:   ActionUsersWrite,
+			resource: ResourceRef{TenantIDs: []string{"tenant-a"}},
+			wantErr:  nil,
+		},
+		{
+			name: "tenant admin denied other tenant join tokens",
+			subject: Subject{
+				Role:             storage.RoleTenantAdmin,
+				AllowedTenantIDs: []string{"tenant-a"},
+			},
+			action:   ActionJoinTokensWrite,
+			resource: ResourceRef{TenantIDs: []string{"tenant-b"}},
+			wantErr:  ErrForbidden,
+		},
+		{
+			name: "tenant admin denied server settings",
+			subject: Subject{
+				Role:             storage.RoleTenantAdmin,
+				AllowedTenantIDs: []string{"tenant-a"},
+			},
+			action:   ActionSettingsServerWrite,
+			resource: ResourceRef{},
+			wantErr:  ErrForbidden,
+		},
+		{
+			name: "tenant admin denied creating tenants",
+			subject: Subject{
+				Role:             storage.RoleTenantAdmin,
+				AllowedTenantIDs: []string{"tenant-a"},
+			},
+			action:   ActionTenantsWrite,
+			resource: ResourceRef{TenantIDs: []string{"tenant-a"}},
+			wantErr:  ErrForbidden,
+		},
+		{
+			name: "auditor allowed to read audit log",
+			subject: Subject{
+				Role:             storage.RoleAuditor,
+				AllowedTenantIDs: []string{"tenant-a"},
+			},
+			action:   ActionAuditLogsRead,
+			resource: ResourceRef{TenantIDs: []string{"tenant-a"}},
+			wantErr:  nil,
+		},
+		{
+			name: "auditor denied writing device notes",
+			subject: Subject{
+				Role:             storage.RoleAuditor,
+				AllowedTenantIDs: []string{"tenant-a"},
+			},
+			action:   ActionDeviceNotesWrite,
+			resource: ResourceRef{TenantIDs: []string{"tenant-a"}},
+			wantErr:  ErrForbidden,
+		},
+		{
+			name: "auditor denied approving devices",
+			subject: Subject{
+				Role:             storage.RoleAuditor,
+				AllowedTenantIDs: []string{"tenant-a"},
+			},
+			action:   ActionDevicesApprove,
+			resource: ResourceRef{TenantIDs: []string{"tenant-a"}},
+			wantErr:  ErrForbidden,
+		},
 		{
 			name: "viewer allowed to read in-scope agent",
 			subject: Subject{
@@ -0,0 +1,341 @@
+// Package billing computes the per-tenant monthly counters exported to MSP
+// billing systems: devices managed, mono/color pages printed and agents
+// online. Open periods are computed live from device metrics; once a period
+// is finalized its counters are stored and returned unchanged from then on, so
+// invoices never drift when late metrics arrive or devices are deleted.
+package billing
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+
+	"printmaster/server/storage"
+)
+
+// Store is the subset of storage.Store used to compute billing periods.
+type Store interface {
+	ListTenants(ctx context.Context) ([]*storage.Tenant, error)
+	ListAgents(ctx context.Context) ([]*storage.Agent, error)
+	ListAllDevices(ctx context.Context) ([]*storage.Device, error)
+	ListUnapprovedDeviceSerials(ctx context.Context) (map[string]struct{}, error)
+	GetMetricsAtOrBefore(ctx context.Context, serial string, at time.Time) (*storage.MetricsSnapshot, error)
+	GetMetricsHistory(ctx context.Context, serial string, since time.Time) ([]*storage.MetricsSnapshot, error)
+	FinalizeBillingPeriod(ctx context.Context, bp *storage.BillingPeriod) error
+	ListBillingPeriods(ctx context.Context, filter storage.BillingPeriodFilter) ([]*storage.BillingPeriod, error)
+}
+
+// ErrPeriodNotEnded is returned when finalizing a period that is still running.
+var ErrPeriodNotEnded = errors.New("billing period has not ended")
+
+// Period is a calendar month in UTC.
+type Period struct {
+	Start time.Time // First instant of the month
+	End   time.Time // First instant of the next month (exclusive)
+}
+
+// ParsePeriod parses a "YYYY-MM" period.
+func ParsePeriod(s string) (Period, error) {
+	t, err := time.Parse("2006-01", s)
+	if err != nil {
+		return Period{}, fmt.Errorf("invalid period %q (want YYYY-MM)", s)
+	}
+	return PeriodOf(t), nil
+}
+
+// PeriodOf returns the period containing t.
+func PeriodOf(t time.Time) Period {
+	t = t.UTC()
+	start := time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+	return Period{Start: start, End: start.AddDate(0, 1, 0)}
+}
+
+// String returns the period as "YYYY-MM".
+func (p Period) String() string {
+	return p.Start.Format("2006-01")
+}
+
+// Service computes and finalizes billing periods.
+type Service struct {
+	store Store
+	now   func() time.Time
+}
+
+// NewService creates a billing service backed by store.
+func NewService(store Store) *Service {
+	return &Service{store: store, now: func() time.Time { return time.Now().UTC() }}
+}
+
+// Export returns the counters of every requested tenant (all tenants when
+// tenantIDs is empty) for a period. Finalized tenants are returned as stored;
+// the rest are computed live and reported as open.
+func (s *Service) Export(ctx context.Context, p Period, tenantIDs []string) ([]*storage.BillingPeriod, error) {
+	finalized, err := s.finalizedByTenant(ctx, p, tenantIDs)
+	if err != nil {
+		return nil, err
+	}
+	tenants, err := s.tenants(ctx, tenantIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	var open []*storage.Tenant
+	for _, t := range tenants {
+		if _, ok := finalized[t.ID]; !ok {
+			open = append(open, t)
+		}
+	}
+	computed, err := s.compute(ctx, p, open)
+	if err != nil {
+		return nil, err
+	}
+
+	out := make([]*storage.BillingPeriod, 0, len(finalized)+len(computed))
+	for _, bp := range finalized {
+		out = append(out, bp)
+	}
+	out = append(out, computed...)
+	names := make(map[string]string, len(tenants))
+	for _, t := range tenants {
+		names[t.ID] = t.Name
+	}
+	for _, bp := range out {
+		if bp.TenantName == "" {
+			bp.TenantName = names[bp.TenantID]
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].TenantID < out[j].TenantID })
+	return out, nil
+}
+
+// Finalize locks the period for the requested tenants (all tenants when
+// tenantIDs is empty). Tenants that were already finalized keep their stored
+// counters. Only periods that have ended can be finalized.
+func (s *Service) Finalize(ctx context.Context, p Period, tenantIDs []string, finalizedBy string) ([]*storage.BillingPeriod, error) {
+	if s.now().Before(p.End) {
+		return nil, ErrPeriodNotEnded
+	}
+	periods, err := s.Export(ctx, p, tenantIDs)
+	if err != nil {
+		return nil, err
+	}
+	now := s.now()
+	for i, bp := range periods {
+		if bp.Status == storage.BillingPeriodFinalized {
+			continue
+		}
+		bp.Status = storage.BillingPeriodFinalized
+		bp.FinalizedAt = now
+		bp.FinalizedBy = finalizedBy
+		err := s.store.FinalizeBillingPeriod(ctx, bp)
+		if errors.Is(err, storage.ErrBillingPeriodFinalized) {
+			// Finalized concurrently; the stored row wins
+			stored, lerr := s.store.ListBillingPeriods(ctx, storage.BillingPeriodFilter{Period: p.String(), TenantIDs: []string{bp.TenantID}})
+			if lerr != nil || len(stored) == 0 {
+				return nil, fmt.Errorf("reload finalized period for tenant %s: %w", bp.TenantID, lerr)
+			}
+			stored[0].TenantName = bp.TenantName
+			periods[i] = stored[0]
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("finalize tenant %s: %w", bp.TenantID, err)
+		}
+	}
+	return periods, nil
+}
+
+func (s *Service) finalizedByTenant(ctx context.Context, p Period, tenantIDs []string) (map[string]*storage.BillingPeriod, error) {
+	stored, err := s.store.ListBillingPeriods(ctx, storage.BillingPeriodFilter{Period: p.String(), TenantIDs: tenantIDs})
+	if err != nil {
+		return nil, fmt.Errorf("list billing periods: %w", err)
+	}
+	out := make(map[string]*storage.BillingPeriod, len(stored))
+	for _, bp := range stored {
+		out[bp.TenantID] = bp
+	}
+	return out, nil
+}
+
+func (s *Service) tenants(ctx context.Context, tenantIDs []string) ([]*storage.Tenant, error) {
+	all, err := s.store.ListTenants(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("list tenants: %w", err)
+	}
+	if len(tenantIDs) == 0 {
+		return all, nil
+	}
+	want := make(map[string]bool, len(tenantIDs))
+	for _, id := range tenantIDs {
+		want[id] = true
+	}
+	var out []*storage.Tenant
+	for _, t := range all {
+		if want[t.ID] {
+			out = append(out, t)
+		}
+	}
+	return out, nil
+}
+
+// compute derives live counters for the given tenants.
+func (s *Service) compute(ctx context.Context, p Period, tenants []*storage.Tenant) ([]*storage.BillingPeriod, error) {
+	if len(tenants) == 0 {
+		return nil, nil
+	}
+	agents, err := s.store.ListAgents(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("list agents: %w", err)
+	}
+	devices, err := s.store.ListAllDevices(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("list devices: %w", err)
+	}
+	unapproved, err := s.store.ListUnapprovedDeviceSerials(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("list unapproved devices: %w", err)
+	}
+
+	now := s.now()
+	byTenant := make(map[string]*storage.BillingPeriod, len(tenants))
+	out := make([]*storage.BillingPeriod, 0, len(tenants))
+	for _, t := range tenants {
+		bp := &storage.BillingPeriod{
+			TenantID:      t.ID,
+			TenantName:    t.Name,
+			Period:        p.String(),
+			PeriodStart:   p.Start,
+			PeriodEnd:     p.End,
+			Status:        storage.BillingPeriodOpen,
+			ComputedAt:    now,
+			SchemaVersion: storage.BillingSchemaVersion,
+		}
+		byTenant[t.ID] = bp
+		out = append(out, bp)
+	}
+
+	// Devices carry no tenant; resolve it through the discovering agent
+	agentTenant := make(map[string]string, len(agents))
+	for _, a := range agents {
+		agentTenant[a.AgentID] = a.TenantID
+		bp := byTenant[a.TenantID]
+		if bp == nil || (!a.RegisteredAt.IsZero() && !a.RegisteredAt.Before(p.End)) {
+			continue
+		}
+		bp.AgentsTotal++
+		if !a.LastSeen.Before(p.Start) {
+			bp.AgentsOnline++
+		}
+	}
+
+	for _, d := range devices {
+		bp := byTenant[agentTenant[d.AgentID]]
+		if bp == nil || !activeDuring(d, p) {
+			continue
+		}
+		if _, skip := unapproved[d.Serial]; skip {
+			continue
+		}
+		mono, color, total, err := s.pagesPrinted(ctx, d.Serial, p)
+		if err != nil {
+			return nil, fmt.Errorf("metrics for %s: %w", d.Serial, err)
+		}
+		bp.DevicesManaged++
+		bp.PagesMono += mono
+		bp.PagesColor += color
+		bp.PagesTotal += total
+	}
+	return out, nil
+}
+
+// activeDuring reports whether a device was known before the period ended and
+// reported at some point during it.
+func activeDuring(d *storage.Device, p Period) bool {
+	if !d.FirstSeen.IsZero() && !d.FirstSeen.Before(p.End) {
+		return false
+	}
+	return !d.LastSeen.Before(p.Start)
+}
+
+// pagesPrinted returns the counter deltas of a device across the period. The
+// baseline is the last snapshot before the period starts, or the first one in
+// the period for devices added mid-month. Negative deltas (counter resets,
+// board replacements) count as zero.
+func (s *Service) pagesPrinted(ctx context.Context, serial string, p Period) (mono, color, total int64, err error) {
+	end, err := s.store.GetMetricsAtOrBefore(ctx, serial, p.End.Add(-time.Nanosecond))
+	if err != nil || end == nil || end.Timestamp.Before(p.Start) {
+		return 0, 0, 0, err
+	}
+	base, err := s.store.GetMetricsAtOrBefore(ctx, serial, p.Start)
+	if err != nil {
+		return 0, 0, 0, err
+	}
+	if base == nil {
+		hist, err := s.store.GetMetricsHistory(ctx, serial, p.Start)
+		if err != nil {
+			return 0, 0, 0, err
+		}
+		for _, snap := range hist {
+			if snap != nil {
+				base = snap
+				break
+			}
+		}
+	}
+	if base == nil {
+		return 0, 0, 0, nil
+	}
+
+	mono = counterDelta(base.MonoPages, end.MonoPages)
+	color = counterDelta(base.ColorPages, end.ColorPages)
+	total = counterDelta(base.PageCount, end.PageCount)
+	if total == 0 {
+		total = mono + color
+	}
+	return mono, color, total, nil
+}
+
+func counterDelta(from, to int) int64 {
+	if to <= from {
+		return 0
+	}
+	return int64(to - from)
+}
+
+// CSVHeader is the fixed column order of the CSV export. Columns are only ever
+// appended so positional parsers keep working.
+var CSVHeader = []string{
+	"schema_version", "period", "tenant_id", "tenant_name", "status",
+	"devices_managed", "pages_mono", "pages_color", "pages_total",
+	"agents_online", "agents_total", "period_start", "period_end",
+	"computed_at", "finalized_at", "finalized_by",
+}
+
+// CSVRecord renders a billing period in CSVHeader order.
+func CSVRecord(bp *storage.BillingPeriod) []string {
+	finalizedAt := ""
+	if !bp.FinalizedAt.IsZero() {
+		finalizedAt = bp.FinalizedAt.UTC().Format(time.RFC3339)
+	}
+	return []string{
+		strconv.Itoa(bp.SchemaVersion),
+		bp.Period,
+		bp.TenantID,
+		bp.TenantName,
+		bp.Status,
+		strconv.Itoa(bp.DevicesManaged),
+		strconv.FormatInt(bp.PagesMono, 10),
+		strconv.FormatInt(bp.PagesColor, 10),
+		strconv.FormatInt(bp.PagesTotal, 10),
+		strconv.Itoa(bp.AgentsOnline),
+		strconv.Itoa(bp.AgentsTotal),
+		bp.PeriodStart.UTC().Format(time.RFC3339),
+		bp.PeriodEnd.UTC().Format(time.RFC3339),
+		bp.ComputedAt.UTC().Format(time.RFC3339),
+		finalizedAt,
+		bp.FinalizedBy,
+	}
+}
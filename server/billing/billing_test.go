@@ -0,0 +1,214 @@
+package billing
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"printmaster/server/storage"
+)
+
+type fakeStore struct {
+	tenants    []*storage.Tenant
+	agents     []*storage.Agent
+	devices    []*storage.Device
+	unapproved map[string]struct{}
+	metrics    map[string][]*storage.MetricsSnapshot // ascending by timestamp
+	finalized  []*storage.BillingPeriod
+}
+
+func (f *fakeStore) ListTenants(context.Context) ([]*storage.Tenant, error) { return f.tenants, nil }
+func (f *fakeStore) ListAgents(context.Context) ([]*storage.Agent, error)   { return f.agents, nil }
+func (f *fakeStore) ListAllDevices(context.Context) ([]*storage.Device, error) {
+	return f.devices, nil
+}
+func (f *fakeStore) ListUnapprovedDeviceSerials(context.Context) (map[string]struct{}, error) {
+	return f.unapproved, nil
+}
+
+func (f *fakeStore) GetMetricsAtOrBefore(_ context.Context, serial string, at time.Time) (*storage.MetricsSnapshot, error) {
+	var found *storage.MetricsSnapshot
+	for _, m := range f.metrics[serial] {
+		if !m.Timestamp.After(at) {
+			found = m
+		}
+	}
+	return found, nil
+}
+
+func (f *fakeStore) GetMetricsHistory(_ context.Context, serial string, since time.Time) ([]*storage.MetricsSnapshot, error) {
+	var out []*storage.MetricsSnapshot
+	for _, m := range f.metrics[serial] {
+		if !m.Timestamp.Before(since) {
+			out = append(out, m)
+		}
+	}
+	return out, nil
+}
+
+func (f *fakeStore) FinalizeBillingPeriod(_ context.Context, bp *storage.BillingPeriod) error {
+	for _, existing := range f.finalized {
+		if existing.TenantID == bp.TenantID && existing.Period == bp.Period {
+			return storage.ErrBillingPeriodFinalized
+		}
+	}
+	stored := *bp
+	stored.Status = storage.BillingPeriodFinalized
+	f.finalized = append(f.finalized, &stored)
+	return nil
+}
+
+func (f *fakeStore) ListBillingPeriods(_ context.Context, filter storage.BillingPeriodFilter) ([]*storage.BillingPeriod, error) {
+	var out []*storage.BillingPeriod
+	for _, bp := range f.finalized {
+		if filter.Period != "" && bp.Period != filter.Period {
+			continue
+		}
+		if len(filter.TenantIDs) > 0 && !contains(filter.TenantIDs, bp.TenantID) {
+			continue
+		}
+		copied := *bp
+		out = append(out, &copied)
+	}
+	return out, nil
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+func snap(serial string, ts time.Time, mono, color int) *storage.MetricsSnapshot {
+	return &storage.MetricsSnapshot{Serial: serial, Timestamp: ts, MonoPages: mono, ColorPages: color, PageCount: mono + color}
+}
+
+func device(serial, agentID string, firstSeen, lastSeen time.Time) *storage.Device {
+	d := &storage.Device{AgentID: agentID}
+	d.Serial = serial
+	d.FirstSeen = firstSeen
+	d.LastSeen = lastSeen
+	return d
+}
+
+func newFixture() (*fakeStore, Period) {
+	p, _ := ParsePeriod("2026-09")
+	day := func(month time.Month, d int) time.Time { return time.Date(2026, month, d, 12, 0, 0, 0, time.UTC) }
+
+	store := &fakeStore{
+		tenants: []*storage.Tenant{{ID: "acme", Name: "Acme"}, {ID: "globex", Name: "Globex"}},
+		agents: []*storage.Agent{
+			{AgentID: "a1", TenantID: "acme", RegisteredAt: day(1, 1), LastSeen: day(10, 2)},
+			{AgentID: "a2", TenantID: "acme", RegisteredAt: day(1, 1), LastSeen: day(8, 1)},   // offline all of September
+			{AgentID: "a3", TenantID: "acme", RegisteredAt: day(10, 5), LastSeen: day(10, 6)}, // registered after the period
+			{AgentID: "g1", TenantID: "globex", RegisteredAt: day(1, 1), LastSeen: day(9, 20)},
+		},
+		devices: []*storage.Device{
+			device("P1", "a1", day(1, 1), day(10, 2)),
+			device("P2", "a1", day(9, 15), day(10, 2)), // added mid-month
+			device("P3", "a1", day(1, 1), day(10, 2)),  // pending approval
+			device("P4", "a1", day(10, 3), day(10, 3)), // first seen after the period
+			device("G1", "g1", day(1, 1), day(9, 20)),
+		},
+		unapproved: map[string]struct{}{"P3": {}},
+		metrics: map[string][]*storage.MetricsSnapshot{
+			"P1": {snap("P1", day(8, 31), 1000, 200), snap("P1", day(9, 30), 1500, 260), snap("P1", day(10, 2), 9000, 9000)},
+			"P2": {snap("P2", day(9, 15), 50, 0), snap("P2", day(9, 29), 80, 0)},
+			"P3": {snap("P3", day(8, 31), 0, 0), snap("P3", day(9, 30), 999, 999)},
+			"G1": {snap("G1", day(8, 31), 500, 0), snap("G1", day(9, 20), 100, 0)}, // counter reset
+		},
+	}
+	return store, p
+}
+
+func TestExportComputesOpenPeriod(t *testing.T) {
+	t.Parallel()
+	store, p := newFixture()
+	svc := NewService(store)
+
+	periods, err := svc.Export(context.Background(), p, nil)
+	if err != nil {
+		t.Fatalf("Export: %v", err)
+	}
+	if len(periods) != 2 {
+		t.Fatalf("got %d periods, want 2", len(periods))
+	}
+	acme := periods[0]
+	if acme.TenantID != "acme" || acme.TenantName != "Acme" || acme.Status != storage.BillingPeriodOpen {
+		t.Fatalf("unexpected acme period: %+v", acme)
+	}
+	if acme.DevicesManaged != 2 {
+		t.Errorf("DevicesManaged = %d, want 2", acme.DevicesManaged)
+	}
+	if acme.PagesMono != 530 || acme.PagesColor != 60 || acme.PagesTotal != 590 {
+		t.Errorf("pages = mono %d color %d total %d, want 530/60/590", acme.PagesMono, acme.PagesColor, acme.PagesTotal)
+	}
+	if acme.AgentsOnline != 1 || acme.AgentsTotal != 2 {
+		t.Errorf("agents = %d/%d, want 1/2", acme.AgentsOnline, acme.AgentsTotal)
+	}
+
+	globex := periods[1]
+	if globex.DevicesManaged != 1 || globex.PagesTotal != 0 {
+		t.Errorf("globex counter reset should bill zero pages: %+v", globex)
+	}
+}
+
+func TestFinalizeLocksCounters(t *testing.T) {
+	t.Parallel()
+	store, p := newFixture()
+	svc := NewService(store)
+	ctx := context.Background()
+
+	svc.now = func() time.Time { return p.End.Add(-time.Hour) }
+	if _, err := svc.Finalize(ctx, p, nil, "billing"); !errors.Is(err, ErrPeriodNotEnded) {
+		t.Fatalf("Finalize before period end: err = %v, want ErrPeriodNotEnded", err)
+	}
+
+	svc.now = func() time.Time { return p.End.Add(24 * time.Hour) }
+	finalized, err := svc.Finalize(ctx, p, []string{"acme"}, "billing")
+	if err != nil {
+		t.Fatalf("Finalize: %v", err)
+	}
+	if len(finalized) != 1 || finalized[0].Status != storage.BillingPeriodFinalized || finalized[0].FinalizedBy != "billing" {
+		t.Fatalf("unexpected finalize result: %+v", finalized)
+	}
+
+	// Late metrics must not change the finalized numbers
+	store.metrics["P1"] = append(store.metrics["P1"][:1], snap("P1", time.Date(2026, 9, 30, 23, 0, 0, 0, time.UTC), 5000, 5000))
+	periods, err := svc.Export(ctx, p, nil)
+	if err != nil {
+		t.Fatalf("Export: %v", err)
+	}
+	if periods[0].PagesMono != 530 || periods[0].Status != storage.BillingPeriodFinalized || periods[0].TenantName != "Acme" {
+		t.Errorf("finalized period changed: %+v", periods[0])
+	}
+	if periods[1].Status != storage.BillingPeriodOpen {
+		t.Errorf("globex should still be open: %+v", periods[1])
+	}
+
+	// Finalizing again is a no-op that returns the stored row
+	again, err := svc.Finalize(ctx, p, []string{"acme"}, "someone-else")
+	if err != nil || again[0].FinalizedBy != "billing" {
+		t.Errorf("re-finalize = %+v, %v", again, err)
+	}
+}
+
+func TestParsePeriod(t *testing.T) {
+	t.Parallel()
+	p, err := ParsePeriod("2026-12")
+	if err != nil {
+		t.Fatalf("ParsePeriod: %v", err)
+	}
+	if !p.End.Equal(time.Date(2027, 1, 1, 0, 0, 0, 0, time.UTC)) || p.String() != "2026-12" {
+		t.Errorf("unexpected period: %v", p)
+	}
+	for _, bad := range []string{"", "2026", "2026-13", "09-2026"} {
+		if _, err := ParsePeriod(bad); err == nil {
+			t.Errorf("ParsePeriod(%q) should fail", bad)
+		}
+	}
+}
@@ -0,0 +1,383 @@
+package billing
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+
+	"printmaster/common/meterread"
+	"printmaster/server/storage"
+)
+
+// MeterReadStore is the subset of storage.Store used to reconcile certified
+// meter reads.
+type MeterReadStore interface {
+	ListTenants(ctx context.Context) ([]*storage.Tenant, error)
+	ListMeterReads(ctx context.Context, filter storage.MeterReadFilter) ([]*storage.MeterRead, error)
+	RecordMeterReadAnomaly(ctx context.Context, a *storage.MeterReadAnomaly) error
+	ListMeterReadAnomalies(ctx context.Context, filter storage.MeterReadAnomalyFilter) ([]*storage.MeterReadAnomaly, error)
+}
+
+// Reconciliation thresholds.
+const (
+	// A counter that drops below this fraction of the previous read is
+	// treated as reset (new formatter board); anything higher is a rollback.
+	resetRatio = 0.1
+	// A delta this many times the previous delta, and at least spikeMinPages,
+	// is flagged as a usage spike.
+	spikeFactor   = 5
+	spikeMinPages = 1000
+)
+
+// Reconciled read states.
+const (
+	ReadStatusOK       = "ok"       // Delta counts towards the contract
+	ReadStatusBaseline = "baseline" // First read of the device; no delta yet
+	ReadStatusReview   = "review"   // Open anomalies; delta held out of totals
+	ReadStatusAdjusted = "adjusted" // Delta replaced by a reviewer
+)
+
+// ReconciledRead is a device's billing read for a period together with the
+// delta against its previous certified read.
+type ReconciledRead struct {
+	TenantID       string                      `json:"tenant_id,omitempty"`
+	Serial         string                      `json:"serial"`
+	Manufacturer   string                      `json:"manufacturer,omitempty"`
+	Model          string                      `json:"model,omitempty"`
+	Period         string                      `json:"period"`
+	ReadID         string                      `json:"read_id"`
+	ReadAt         time.Time                   `json:"read_at"`
+	PageCount      int64                       `json:"page_count"`
+	MonoPages      int64                       `json:"mono_pages"`
+	ColorPages     int64                       `json:"color_pages"`
+	PreviousReadID string                      `json:"previous_read_id,omitempty"`
+	PreviousReadAt time.Time                   `json:"previous_read_at,omitzero"`
+	PreviousPeriod string                      `json:"previous_period,omitempty"`
+	DeltaMono      int64                       `json:"delta_mono"`
+	DeltaColor     int64                       `json:"delta_color"`
+	DeltaTotal     int64                       `json:"delta_total"`
+	Status         string                      `json:"status"`
+	Anomalies      []*storage.MeterReadAnomaly `json:"anomalies,omitempty"`
+}
+
+// ContractReconciliation groups a period's reconciled reads by billing
+// contract (the tenant's billing code, or its ID when no code is set).
+type ContractReconciliation struct {
+	Contract      string            `json:"contract"`
+	TenantIDs     []string          `json:"tenant_ids"`
+	Period        string            `json:"period"`
+	Devices       int               `json:"devices"`
+	PagesMono     int64             `json:"pages_mono"`
+	PagesColor    int64             `json:"pages_color"`
+	PagesTotal    int64             `json:"pages_total"`
+	PendingReview int               `json:"pending_review"` // Reads held out of the totals
+	Reads         []*ReconciledRead `json:"reads"`
+}
+
+// Reconciler reconciles certified meter reads between billing periods.
+type Reconciler struct {
+	store MeterReadStore
+}
+
+// NewReconciler creates a reconciler backed by store.
+func NewReconciler(store MeterReadStore) *Reconciler {
+	return &Reconciler{store: store}
+}
+
+// Reconcile computes each device's delta for the period (all tenants when
+// tenantIDs is empty), records newly detected anomalies for review and groups
+// the result by contract. Reads with open anomalies are listed but excluded
+// from contract totals until reviewed.
+func (r *Reconciler) Reconcile(ctx context.Context, p Period, tenantIDs []string) ([]*ContractReconciliation, error) {
+	reads, err := r.store.ListMeterReads(ctx, storage.MeterReadFilter{Period: p.String(), TenantIDs: tenantIDs})
+	if err != nil {
+		return nil, fmt.Errorf("list meter reads: %w", err)
+	}
+	tenants, err := r.store.ListTenants(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("list tenants: %w", err)
+	}
+	contractOf := make(map[string]string, len(tenants))
+	for _, t := range tenants {
+		contractOf[t.ID] = t.ID
+		if t.BillingCode != "" {
+			contractOf[t.ID] = t.BillingCode
+		}
+	}
+
+	bySerial := make(map[string][]*storage.MeterRead)
+	for _, read := range reads {
+		bySerial[read.Serial] = append(bySerial[read.Serial], read)
+	}
+
+	contracts := make(map[string]*ContractReconciliation)
+	for serial, candidates := range bySerial {
+		cur := billingRead(candidates)
+		history, err := r.store.ListMeterReads(ctx, storage.MeterReadFilter{Serial: serial, Before: cur.ReadAt})
+		if err != nil {
+			return nil, fmt.Errorf("meter read history for %s: %w", serial, err)
+		}
+		prev, older := previousBillingRead(history, cur.Period)
+		var prevPrev *storage.MeterRead
+		if prev != nil {
+			prevPrev, _ = previousBillingRead(older, prev.Period)
+		}
+
+		rr, detected := ReconcileRead(prev, prevPrev, cur)
+		for _, a := range detected {
+			if err := r.store.RecordMeterReadAnomaly(ctx, a); err != nil {
+				return nil, fmt.Errorf("record anomaly for %s: %w", serial, err)
+			}
+		}
+		if len(detected) > 0 {
+			stored, err := r.store.ListMeterReadAnomalies(ctx, storage.MeterReadAnomalyFilter{ReadIDs: []string{cur.ID}})
+			if err != nil {
+				return nil, fmt.Errorf("list anomalies for %s: %w", serial, err)
+			}
+			applyReviews(rr, stored)
+		}
+
+		key := contractOf[cur.TenantID]
+		if key == "" {
+			key = cur.TenantID
+		}
+		c := contracts[key]
+		if c == nil {
+			c = &ContractReconciliation{Contract: key, Period: p.String()}
+			contracts[key] = c
+		}
+		if cur.TenantID != "" && !containsString(c.TenantIDs, cur.TenantID) {
+			c.TenantIDs = append(c.TenantIDs, cur.TenantID)
+		}
+		c.Devices++
+		c.Reads = append(c.Reads, rr)
+		if rr.Status == ReadStatusReview {
+			c.PendingReview++
+			continue
+		}
+		c.PagesMono += rr.DeltaMono
+		c.PagesColor += rr.DeltaColor
+		c.PagesTotal += rr.DeltaTotal
+	}
+
+	out := make([]*ContractReconciliation, 0, len(contracts))
+	for _, c := range contracts {
+		sort.Strings(c.TenantIDs)
+		sort.Slice(c.Reads, func(i, j int) bool { return c.Reads[i].Serial < c.Reads[j].Serial })
+		out = append(out, c)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Contract < out[j].Contract })
+	return out, nil
+}
+
+// ReconcileRead computes the delta between cur and the device's previous
+// billing read and returns the anomalies detected. prevPrev, the read before
+// prev, is used to spot usage spikes; both may be nil.
+func ReconcileRead(prev, prevPrev, cur *storage.MeterRead) (*ReconciledRead, []*storage.MeterReadAnomaly) {
+	rr := &ReconciledRead{
+		TenantID:     cur.TenantID,
+		Serial:       cur.Serial,
+		Manufacturer: cur.Manufacturer,
+		Model:        cur.Model,
+		Period:       cur.Period,
+		ReadID:       cur.ID,
+		ReadAt:       cur.ReadAt,
+		PageCount:    cur.PageCount,
+		MonoPages:    cur.MonoPages,
+		ColorPages:   cur.ColorPages,
+		Status:       ReadStatusOK,
+	}
+	var anomalies []*storage.MeterReadAnomaly
+	flag := func(kind, details string) {
+		anomalies = append(anomalies, &storage.MeterReadAnomaly{
+			ReadID:   cur.ID,
+			Serial:   cur.Serial,
+			TenantID: cur.TenantID,
+			Period:   cur.Period,
+			Kind:     kind,
+			Details:  details,
+			Status:   storage.MeterAnomalyOpen,
+		})
+	}
+
+	if c := cur.Confirmation; !c.SerialMatch && c.ReportedSerial != "" {
+		flag(storage.MeterAnomalySerialMismatch, fmt.Sprintf("device reported serial %s", c.ReportedSerial))
+	}
+	if prev == nil {
+		rr.Status = ReadStatusBaseline
+		rr.Anomalies = anomalies
+		if len(anomalies) > 0 {
+			rr.Status = ReadStatusReview
+		}
+		return rr, anomalies
+	}
+
+	rr.PreviousReadID = prev.ID
+	rr.PreviousReadAt = prev.ReadAt
+	rr.PreviousPeriod = prev.Period
+	if want := previousPeriod(cur.Period); prev.Period != want {
+		flag(storage.MeterAnomalyMissedPeriod, fmt.Sprintf("no read for %s; previous read is from %s", want, prev.Period))
+	}
+
+	switch {
+	case cur.PageCount < prev.PageCount && float64(cur.PageCount) < float64(prev.PageCount)*resetRatio:
+		// Counter restarted from zero: bill the pages since the reset
+		flag(storage.MeterAnomalyCounterReset, fmt.Sprintf("page count dropped from %d to %d", prev.PageCount, cur.PageCount))
+		rr.DeltaMono, rr.DeltaColor, rr.DeltaTotal = cur.MonoPages, cur.ColorPages, cur.PageCount
+	case cur.PageCount < prev.PageCount:
+		flag(storage.MeterAnomalyCounterRollback, fmt.Sprintf("page count went back from %d to %d", prev.PageCount, cur.PageCount))
+	default:
+		rr.DeltaMono = readDelta(prev.MonoPages, cur.MonoPages)
+		rr.DeltaColor = readDelta(prev.ColorPages, cur.ColorPages)
+		rr.DeltaTotal = cur.PageCount - prev.PageCount
+	}
+	if rr.DeltaTotal == 0 {
+		rr.DeltaTotal = rr.DeltaMono + rr.DeltaColor
+	}
+
+	if prevPrev != nil && prev.PageCount > prevPrev.PageCount {
+		usual := prev.PageCount - prevPrev.PageCount
+		if rr.DeltaTotal >= spikeMinPages && rr.DeltaTotal > usual*spikeFactor {
+			flag(storage.MeterAnomalyUsageSpike, fmt.Sprintf("%d pages against %d the previous period", rr.DeltaTotal, usual))
+		}
+	}
+
+	rr.Anomalies = anomalies
+	if len(anomalies) > 0 {
+		rr.Status = ReadStatusReview
+	}
+	return rr, anomalies
+}
+
+// applyReviews replaces the detected anomalies with their stored state. The
+// read stays under review while any anomaly is open; an adjusted anomaly
+// replaces the delta with the reviewer's figures.
+func applyReviews(rr *ReconciledRead, stored []*storage.MeterReadAnomaly) {
+	rr.Anomalies = stored
+	rr.Status = ReadStatusOK
+	if rr.PreviousReadID == "" {
+		rr.Status = ReadStatusBaseline
+	}
+	var adjusted *storage.MeterReadAnomaly
+	for _, a := range stored {
+		switch a.Status {
+		case storage.MeterAnomalyOpen:
+			rr.Status = ReadStatusReview
+			return
+		case storage.MeterAnomalyAdjusted:
+			if adjusted == nil || a.ResolvedAt.After(adjusted.ResolvedAt) {
+				adjusted = a
+			}
+		}
+	}
+	if adjusted != nil {
+		rr.Status = ReadStatusAdjusted
+		rr.DeltaMono = adjusted.AdjustedMono
+		rr.DeltaColor = adjusted.AdjustedColor
+		rr.DeltaTotal = adjusted.AdjustedMono + adjusted.AdjustedColor
+	}
+}
+
+// billingRead picks the read that represents a device for a period: the
+// newest scheduled read, falling back to the newest manual one. reads must be
+// newest first.
+func billingRead(reads []*storage.MeterRead) *storage.MeterRead {
+	for _, read := range reads {
+		if read.Trigger == meterread.TriggerScheduled {
+			return read
+		}
+	}
+	return reads[0]
+}
+
+// previousBillingRead returns the billing read of the newest period in
+// history other than period, and the reads older than that period. history
+// must be newest first.
+func previousBillingRead(history []*storage.MeterRead, period string) (*storage.MeterRead, []*storage.MeterRead) {
+	start := -1
+	for i, read := range history {
+		if read.Period != period {
+			start = i
+			break
+		}
+	}
+	if start < 0 {
+		return nil, nil
+	}
+	end := start
+	for end < len(history) && history[end].Period == history[start].Period {
+		end++
+	}
+	return billingRead(history[start:end]), history[end:]
+}
+
+// previousPeriod returns the "YYYY-MM" period before period.
+func previousPeriod(period string) string {
+	p, err := ParsePeriod(period)
+	if err != nil {
+		return ""
+	}
+	return PeriodOf(p.Start.Add(-time.Nanosecond)).String()
+}
+
+func readDelta(from, to int64) int64 {
+	if to <= from {
+		return 0
+	}
+	return to - from
+}
+
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// ReconcileCSVHeader is the fixed column order of the reconciled reads CSV.
+// Columns are only ever appended.
+var ReconcileCSVHeader = []string{
+	"contract", "tenant_id", "period", "serial", "manufacturer", "model", "status",
+	"read_id", "read_at", "page_count", "mono_pages", "color_pages",
+	"previous_read_id", "previous_read_at", "delta_mono", "delta_color", "delta_total",
+	"anomalies",
+}
+
+// ReconcileCSVRecord renders a reconciled read in ReconcileCSVHeader order.
+func ReconcileCSVRecord(contract string, rr *ReconciledRead) []string {
+	prevAt := ""
+	if !rr.PreviousReadAt.IsZero() {
+		prevAt = rr.PreviousReadAt.UTC().Format(time.RFC3339)
+	}
+	kinds := ""
+	for i, a := range rr.Anomalies {
+		if i > 0 {
+			kinds += ";"
+		}
+		kinds += a.Kind + ":" + a.Status
+	}
+	return []string{
+		contract,
+		rr.TenantID,
+		rr.Period,
+		rr.Serial,
+		rr.Manufacturer,
+		rr.Model,
+		rr.Status,
+		rr.ReadID,
+		rr.ReadAt.UTC().Format(time.RFC3339),
+		strconv.FormatInt(rr.PageCount, 10),
+		strconv.FormatInt(rr.MonoPages, 10),
+		strconv.FormatInt(rr.ColorPages, 10),
+		rr.PreviousReadID,
+		prevAt,
+		strconv.FormatInt(rr.DeltaMono, 10),
+		strconv.FormatInt(rr.DeltaColor, 10),
+		strconv.FormatInt(rr.DeltaTotal, 10),
+		kinds,
+	}
+}
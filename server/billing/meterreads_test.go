@@ -0,0 +1,136 @@
+package billing
+
+import (
+	"testing"
+	"time"
+
+	"printmaster/common/meterread"
+	"printmaster/server/storage"
+)
+
+func testMeterRead(id, period string, total, mono, color int64) *storage.MeterRead {
+	p, _ := ParsePeriod(period)
+	return &storage.MeterRead{
+		Read: meterread.Read{
+			ID:           id,
+			Serial:       "SN1",
+			Period:       period,
+			Trigger:      meterread.TriggerScheduled,
+			ReadAt:       p.Start.Add(2 * time.Hour),
+			PageCount:    total,
+			MonoPages:    mono,
+			ColorPages:   color,
+			Confirmation: meterread.Confirmation{ReportedSerial: "SN1", SerialMatch: true},
+		},
+		TenantID: "t1",
+	}
+}
+
+func anomalyKinds(anomalies []*storage.MeterReadAnomaly) []string {
+	var kinds []string
+	for _, a := range anomalies {
+		kinds = append(kinds, a.Kind)
+	}
+	return kinds
+}
+
+func TestReconcileRead(t *testing.T) {
+	t.Parallel()
+	julRead := testMeterRead("r7", "2026-07", 9000, 7000, 2000)
+	augRead := testMeterRead("r8", "2026-08", 10000, 8000, 2000)
+
+	tests := []struct {
+		name       string
+		prev       *storage.MeterRead
+		prevPrev   *storage.MeterRead
+		cur        *storage.MeterRead
+		wantStatus string
+		wantDelta  [3]int64 // mono, color, total
+		wantKinds  []string
+	}{
+		{
+			name:       "baseline",
+			cur:        augRead,
+			wantStatus: ReadStatusBaseline,
+		},
+		{
+			name:       "normal",
+			prev:       augRead,
+			prevPrev:   julRead,
+			cur:        testMeterRead("r9", "2026-09", 11500, 9000, 2500),
+			wantStatus: ReadStatusOK,
+			wantDelta:  [3]int64{1000, 500, 1500},
+		},
+		{
+			name:       "reset bills pages since reset",
+			prev:       augRead,
+			cur:        testMeterRead("r9", "2026-09", 300, 200, 100),
+			wantStatus: ReadStatusReview,
+			wantDelta:  [3]int64{200, 100, 300},
+			wantKinds:  []string{storage.MeterAnomalyCounterReset},
+		},
+		{
+			name:       "rollback bills nothing",
+			prev:       augRead,
+			cur:        testMeterRead("r9", "2026-09", 9500, 7600, 1900),
+			wantStatus: ReadStatusReview,
+			wantKinds:  []string{storage.MeterAnomalyCounterRollback},
+		},
+		{
+			name:       "missed period",
+			prev:       julRead,
+			cur:        testMeterRead("r9", "2026-09", 10000, 8000, 2000),
+			wantStatus: ReadStatusReview,
+			wantDelta:  [3]int64{1000, 0, 1000},
+			wantKinds:  []string{storage.MeterAnomalyMissedPeriod},
+		},
+		{
+			name:       "usage spike",
+			prev:       augRead,
+			prevPrev:   julRead,
+			cur:        testMeterRead("r9", "2026-09", 20000, 18000, 2000),
+			wantStatus: ReadStatusReview,
+			wantDelta:  [3]int64{10000, 0, 10000},
+			wantKinds:  []string{storage.MeterAnomalyUsageSpike},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rr, anomalies := ReconcileRead(tt.prev, tt.prevPrev, tt.cur)
+			if rr.Status != tt.wantStatus {
+				t.Errorf("status = %q, want %q", rr.Status, tt.wantStatus)
+			}
+			if got := [3]int64{rr.DeltaMono, rr.DeltaColor, rr.DeltaTotal}; got != tt.wantDelta {
+				t.Errorf("delta = %v, want %v", got, tt.wantDelta)
+			}
+			kinds := anomalyKinds(anomalies)
+			if len(kinds) != len(tt.wantKinds) {
+				t.Fatalf("anomalies = %v, want %v", kinds, tt.wantKinds)
+			}
+			for i := range kinds {
+				if kinds[i] != tt.wantKinds[i] {
+					t.Errorf("anomalies = %v, want %v", kinds, tt.wantKinds)
+				}
+			}
+		})
+	}
+}
+
+func TestReconcileReadSerialMismatch(t *testing.T) {
+	t.Parallel()
+	cur := testMeterRead("r9", "2026-09", 11000, 9000, 2000)
+	cur.Confirmation = meterread.Confirmation{ReportedSerial: "OTHER", SerialMatch: false}
+	_, anomalies := ReconcileRead(testMeterRead("r8", "2026-08", 10000, 8000, 2000), nil, cur)
+	if kinds := anomalyKinds(anomalies); len(kinds) != 1 || kinds[0] != storage.MeterAnomalySerialMismatch {
+		t.Fatalf("anomalies = %v, want serial_mismatch", kinds)
+	}
+}
+
+func TestApplyReviewsAdjusted(t *testing.T) {
+	t.Parallel()
+	rr, _ := ReconcileRead(testMeterRead("r8", "2026-08", 10000, 8000, 2000), nil, testMeterRead("r9", "2026-09", 9500, 7600, 1900))
+	applyReviews(rr, []*storage.MeterReadAnomaly{{Kind: storage.MeterAnomalyCounterRollback, Status: storage.MeterAnomalyAdjusted, AdjustedMono: 400, AdjustedColor: 50}})
+	if rr.Status != ReadStatusAdjusted || rr.DeltaMono != 400 || rr.DeltaColor != 50 || rr.DeltaTotal != 450 {
+		t.Fatalf("unexpected adjusted read: %+v", rr)
+	}
+}
@@ -0,0 +1,154 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	authz "printmaster/server/authz"
+	"printmaster/server/billing"
+	"printmaster/server/storage"
+)
+
+// billingTenantIDs collects tenant IDs from repeated or comma-separated
+// tenant_id query parameters.
+func billingTenantIDs(r *http.Request) []string {
+	var ids []string
+	for _, v := range r.URL.Query()["tenant_id"] {
+		for _, id := range strings.Split(v, ",") {
+			if id = strings.TrimSpace(id); id != "" {
+				ids = append(ids, id)
+			}
+		}
+	}
+	return ids
+}
+
+// billingPeriodParam parses the requested period, defaulting to the previous
+// calendar month (the one normally being invoiced).
+func billingPeriodParam(raw string) (billing.Period, error) {
+	raw = strings.TrimSpace(raw)
+	if raw == "" {
+		return billing.PeriodOf(billing.PeriodOf(time.Now()).Start.Add(-time.Nanosecond)), nil
+	}
+	return billing.ParsePeriod(raw)
+}
+
+func writeBillingPeriods(w http.ResponseWriter, period billing.Period, periods []*storage.BillingPeriod) {
+	if periods == nil {
+		periods = []*storage.BillingPeriod{}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"schema_version": storage.BillingSchemaVersion,
+		"period":         period.String(),
+		"generated_at":   time.Now().UTC(),
+		"tenants":        periods,
+	})
+}
+
+// handleBillingExport returns per-tenant billing counters for a month
+// (GET ?period=YYYY-MM&tenant_id=&format=json|csv). Finalized tenants are
+// returned exactly as locked; others are computed live with status "open".
+func handleBillingExport(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "GET only", http.StatusMethodNotAllowed)
+		return
+	}
+	tenantIDs := billingTenantIDs(r)
+	if !authorizeOrReject(w, r, authz.ActionBillingRead, authz.ResourceRef{TenantIDs: tenantIDs}) {
+		return
+	}
+	period, err := billingPeriodParam(r.URL.Query().Get("period"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	periods, err := billing.NewService(serverStore).Export(r.Context(), period, tenantIDs)
+	if err != nil {
+		logError("Failed to export billing period", "period", period.String(), "error", err)
+		http.Error(w, "failed to export billing period", http.StatusInternalServerError)
+		return
+	}
+
+	switch strings.ToLower(strings.TrimSpace(r.URL.Query().Get("format"))) {
+	case "", "json":
+		writeBillingPeriods(w, period, periods)
+	case "csv":
+		w.Header().Set("Content-Type", "text/csv")
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"billing-%s.csv\"", period.String()))
+		cw := csv.NewWriter(w)
+		cw.Write(billing.CSVHeader)
+		for _, bp := range periods {
+			cw.Write(billing.CSVRecord(bp))
+		}
+		cw.Flush()
+	default:
+		http.Error(w, "format must be json or csv", http.StatusBadRequest)
+	}
+}
+
+// handleBillingFinalize locks a finished month for the given tenants (all
+// tenants when none are listed). Already finalized tenants are returned
+// unchanged, so the billing system can safely retry.
+func handleBillingFinalize(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "POST only", http.StatusMethodNotAllowed)
+		return
+	}
+	var req struct {
+		Period    string   `json:"period"`
+		TenantIDs []string `json:"tenant_ids"`
+	}
+	if err := decodeJSONBody(r, &req); err != nil {
+		http.Error(w, "invalid JSON", http.StatusBadRequest)
+		return
+	}
+	if !authorizeOrReject(w, r, authz.ActionBillingWrite, authz.ResourceRef{TenantIDs: req.TenantIDs}) {
+		return
+	}
+	if strings.TrimSpace(req.Period) == "" {
+		http.Error(w, "period is required", http.StatusBadRequest)
+		return
+	}
+	period, err := billing.ParsePeriod(strings.TrimSpace(req.Period))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	actor := ""
+	if principal := getPrincipal(r); principal != nil && principal.User != nil {
+		actor = principal.User.Username
+	}
+	periods, err := billing.NewService(serverStore).Finalize(r.Context(), period, req.TenantIDs, actor)
+	if errors.Is(err, billing.ErrPeriodNotEnded) {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+	if err != nil {
+		logError("Failed to finalize billing period", "period", period.String(), "error", err)
+		http.Error(w, "failed to finalize billing period", http.StatusInternalServerError)
+		return
+	}
+
+	logInfo("Billing period finalized", "period", period.String(), "tenants", len(periods), "finalized_by", actor)
+	logAuditEntry(r.Context(), &storage.AuditEntry{
+		ActorType:  storage.AuditActorUser,
+		ActorID:    actor,
+		ActorName:  actor,
+		Action:     "billing.period.finalize",
+		TargetType: "billing_period",
+		TargetID:   period.String(),
+		Details:    fmt.Sprintf("Finalized billing period %s for %d tenants", period.String(), len(periods)),
+		IPAddress:  extractClientIP(r),
+		UserAgent:  r.Header.Get("User-Agent"),
+	})
+
+	writeBillingPeriods(w, period, periods)
+}
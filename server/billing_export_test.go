@@ -0,0 +1,68 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"printmaster/server/billing"
+	"printmaster/server/storage"
+)
+
+func TestHandleBillingExportCSV(t *testing.T) {
+	store := SetupTestStore(t)
+	ctx := context.Background()
+	if err := store.CreateTenant(ctx, &storage.Tenant{ID: "tenant-a", Name: "Tenant A"}); err != nil {
+		t.Fatalf("CreateTenant: %v", err)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/billing/export?period=2026-09&tenant_id=tenant-a&format=csv", nil)
+	req = InjectTestAdmin(req)
+	rr := httptest.NewRecorder()
+	handleBillingExport(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	records, err := csv.NewReader(rr.Body).ReadAll()
+	if err != nil {
+		t.Fatalf("parse CSV: %v", err)
+	}
+	if len(records) != 2 {
+		t.Fatalf("expected header + 1 row, got %d rows", len(records))
+	}
+	if len(records[0]) != len(billing.CSVHeader) || records[1][2] != "tenant-a" || records[1][4] != storage.BillingPeriodOpen {
+		t.Fatalf("unexpected CSV: %v", records)
+	}
+}
+
+func TestHandleBillingExportRequiresAdmin(t *testing.T) {
+	SetupTestStore(t)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/billing/export?tenant_id=tenant-a", nil)
+	req = InjectTestUser(req, NewTestUser(storage.RoleOperator, "tenant-a"))
+	rr := httptest.NewRecorder()
+	handleBillingExport(rr, req)
+
+	if rr.Code != http.StatusForbidden {
+		t.Fatalf("expected 403, got %d", rr.Code)
+	}
+}
+
+func TestHandleBillingFinalizeRejectsOpenPeriod(t *testing.T) {
+	SetupTestStore(t)
+
+	body := []byte(`{"period":"` + time.Now().UTC().Format("2006-01") + `"}`)
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/billing/finalize", bytes.NewReader(body))
+	req = InjectTestAdmin(req)
+	rr := httptest.NewRecorder()
+	handleBillingFinalize(rr, req)
+
+	if rr.Code != http.StatusConflict {
+		t.Fatalf("expected 409, got %d: %s", rr.Code, rr.Body.String())
+	}
+}
@@ -0,0 +1,258 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	authz "printmaster/server/authz"
+	"printmaster/server/storage"
+)
+
+const (
+	changesDefaultLimit   = 500
+	changesMaxLimit       = 5000
+	deviceChangeRetention = 30 * 24 * time.Hour
+)
+
+// DeviceChangeEntry is one item of the change feed response. Upserts carry
+// the current device or latest metrics snapshot; deletes are tombstones with
+// only the serial.
+type DeviceChangeEntry struct {
+	Cursor    int64                    `json:"cursor"`
+	Type      string                   `json:"type"` // device or metrics
+	Op        string                   `json:"op"`   // upsert or delete
+	Serial    string                   `json:"serial"`
+	AgentID   string                   `json:"agent_id,omitempty"`
+	ChangedAt time.Time                `json:"changed_at"`
+	Device    *storage.Device          `json:"device,omitempty"`
+	Metrics   *storage.MetricsSnapshot `json:"metrics,omitempty"`
+}
+
+// DeviceChangesResponse is a page of the change feed. Clients store
+// NextCursor and pass it back to resume; HasMore means another page is ready.
+type DeviceChangesResponse struct {
+	Changes    []DeviceChangeEntry `json:"changes"`
+	NextCursor int64               `json:"next_cursor"`
+	HasMore    bool                `json:"has_more"`
+}
+
+// handleDeviceChanges serves
+// GET /api/v1/changes?cursor=&agent_id=&types=devices,metrics&limit=.
+//
+// It returns devices and metrics changed after cursor, plus tombstones for
+// deleted devices, so external inventories can sync incrementally. Changes
+// to the same device within a page are collapsed into the latest one.
+// cursor=latest returns no changes and the current cursor, for clients that
+// take a full snapshot first. A cursor older than the retained history gets
+// 410 Gone and the client must resync.
+func handleDeviceChanges(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "GET only", http.StatusMethodNotAllowed)
+		return
+	}
+	if !authorizeOrReject(w, r, authz.ActionDevicesRead, authz.ResourceRef{}) {
+		return
+	}
+	principal := getPrincipal(r)
+	scope, ok := tenantScope(principal)
+	if !ok {
+		http.Error(w, "forbidden", http.StatusForbidden)
+		return
+	}
+
+	q := r.URL.Query()
+	limit := changesDefaultLimit
+	if v := q.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			http.Error(w, "invalid limit", http.StatusBadRequest)
+			return
+		}
+		limit = min(n, changesMaxLimit)
+	}
+	var kinds []string
+	if v := strings.TrimSpace(q.Get("types")); v != "" {
+		for _, t := range strings.Split(v, ",") {
+			t = strings.TrimSuffix(strings.ToLower(strings.TrimSpace(t)), "s")
+			switch t {
+			case "metric":
+				kinds = append(kinds, storage.DeviceChangeKindMetrics)
+			case storage.DeviceChangeKindDevice:
+				kinds = append(kinds, t)
+			case "":
+			default:
+				http.Error(w, "unknown type "+t, http.StatusBadRequest)
+				return
+			}
+		}
+	}
+
+	ctx := r.Context()
+	oldest, newest, err := serverStore.GetDeviceChangeBounds(ctx)
+	if err != nil {
+		logError("Changes: failed to read feed bounds", "error", err)
+		http.Error(w, "failed to load changes", http.StatusInternalServerError)
+		return
+	}
+
+	var cursor int64
+	switch v := strings.TrimSpace(q.Get("cursor")); v {
+	case "", "0":
+	case "latest":
+		writeDeviceChanges(w, DeviceChangesResponse{Changes: []DeviceChangeEntry{}, NextCursor: newest})
+		return
+	default:
+		cursor, err = strconv.ParseInt(v, 10, 64)
+		if err != nil || cursor < 0 {
+			http.Error(w, "invalid cursor", http.StatusBadRequest)
+			return
+		}
+		if oldest > 0 && cursor < oldest-1 {
+			http.Error(w, "cursor expired; resync required", http.StatusGone)
+			return
+		}
+	}
+
+	// Scoped users only see changes from their tenants' agents
+	agents, err := serverStore.ListAgents(ctx)
+	if err != nil {
+		logError("Changes: failed to list agents", "error", err)
+		http.Error(w, "failed to load changes", http.StatusInternalServerError)
+		return
+	}
+	allowedAgents := make(map[string]bool, len(agents))
+	for _, a := range agents {
+		if a != nil && tenantAllowed(scope, a.TenantID) {
+			allowedAgents[a.AgentID] = true
+		}
+	}
+	filter := storage.DeviceChangeFilter{After: cursor, Kinds: kinds, Limit: limit + 1}
+	if agentID := strings.TrimSpace(q.Get("agent_id")); agentID != "" {
+		if !allowedAgents[agentID] {
+			http.Error(w, "agent not found", http.StatusNotFound)
+			return
+		}
+		filter.AgentIDs = []string{agentID}
+	} else if scope != nil {
+		for id := range allowedAgents {
+			filter.AgentIDs = append(filter.AgentIDs, id)
+		}
+	}
+
+	resp := DeviceChangesResponse{Changes: []DeviceChangeEntry{}, NextCursor: cursor}
+	if scope != nil && len(filter.AgentIDs) == 0 {
+		// No agents in scope; an empty filter would match everything
+		resp.NextCursor = max(cursor, newest)
+		writeDeviceChanges(w, resp)
+		return
+	}
+
+	changes, err := serverStore.ListDeviceChanges(ctx, filter)
+	if err != nil {
+		logError("Changes: failed to list changes", "error", err)
+		http.Error(w, "failed to load changes", http.StatusInternalServerError)
+		return
+	}
+	if len(changes) > limit {
+		changes = changes[:limit]
+		resp.HasMore = true
+	}
+	if len(changes) > 0 {
+		resp.NextCursor = changes[len(changes)-1].ID
+	}
+
+	entries, err := buildDeviceChangeEntries(ctx, coalesceDeviceChanges(changes), allowedAgents)
+	if err != nil {
+		logError("Changes: failed to load snapshots", "error", err)
+		http.Error(w, "failed to load changes", http.StatusInternalServerError)
+		return
+	}
+	resp.Changes = entries
+	writeDeviceChanges(w, resp)
+}
+
+func writeDeviceChanges(w http.ResponseWriter, resp DeviceChangesResponse) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+// coalesceDeviceChanges keeps only the latest change per device and kind,
+// in cursor order. A device deletion also drops earlier metrics changes for
+// that device.
+func coalesceDeviceChanges(changes []*storage.DeviceChange) []*storage.DeviceChange {
+	latest := make(map[string]int, len(changes))
+	deletedAt := make(map[string]int64)
+	for i, c := range changes {
+		latest[c.Kind+"|"+c.Serial] = i
+		if c.Kind == storage.DeviceChangeKindDevice && c.Op == storage.DeviceChangeOpDelete {
+			deletedAt[c.Serial] = c.ID
+		}
+	}
+	var out []*storage.DeviceChange
+	for i, c := range changes {
+		if latest[c.Kind+"|"+c.Serial] != i {
+			continue
+		}
+		if c.Kind == storage.DeviceChangeKindMetrics && c.ID < deletedAt[c.Serial] {
+			continue
+		}
+		out = append(out, c)
+	}
+	return out
+}
+
+// buildDeviceChangeEntries attaches current snapshots to upserts. Devices
+// deleted or moved out of scope since the change was recorded are skipped;
+// their tombstone or move shows up as its own change.
+func buildDeviceChangeEntries(ctx context.Context, changes []*storage.DeviceChange, allowedAgents map[string]bool) ([]DeviceChangeEntry, error) {
+	entries := make([]DeviceChangeEntry, 0, len(changes))
+	for _, c := range changes {
+		entry := DeviceChangeEntry{
+			Cursor:    c.ID,
+			Type:      c.Kind,
+			Op:        c.Op,
+			Serial:    c.Serial,
+			AgentID:   c.AgentID,
+			ChangedAt: c.ChangedAt,
+		}
+		if c.Op == storage.DeviceChangeOpUpsert {
+			device, err := serverStore.GetDevice(ctx, c.Serial)
+			if err != nil || device == nil || !allowedAgents[device.AgentID] {
+				continue
+			}
+			entry.AgentID = device.AgentID
+			if c.Kind == storage.DeviceChangeKindDevice {
+				entry.Device = device
+			} else {
+				metrics, err := serverStore.GetLatestMetrics(ctx, c.Serial)
+				if err != nil {
+					return nil, fmt.Errorf("metrics %s: %w", c.Serial, err)
+				}
+				if metrics == nil {
+					continue
+				}
+				entry.Metrics = metrics
+			}
+		}
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
+
+// pruneDeviceChanges drops change feed entries older than the retention
+// period. Clients whose cursor falls behind get 410 Gone and resync.
+func pruneDeviceChanges(ctx context.Context) {
+	removed, err := serverStore.DeleteDeviceChangesBefore(ctx, time.Now().Add(-deviceChangeRetention))
+	if err != nil {
+		logWarn("Failed to prune device change feed", "error", err)
+		return
+	}
+	if removed > 0 {
+		logInfo("Pruned old device changes", "count", removed, "retention", fmt.Sprint(deviceChangeRetention))
+	}
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"printmaster/server/storage"
+)
+
+func TestHandleDeviceChanges(t *testing.T) {
+	store := SetupTestStore(t)
+	ctx := context.Background()
+	now := time.Now()
+
+	for _, tn := range []*storage.Tenant{{ID: "tenant-a", Name: "Acme"}, {ID: "tenant-b", Name: "Globex"}} {
+		if err := store.CreateTenant(ctx, tn); err != nil {
+			t.Fatalf("CreateTenant: %v", err)
+		}
+	}
+	for _, a := range []*storage.Agent{
+		{AgentID: "agent-a", Hostname: "acme-pc", Token: "ta", TenantID: "tenant-a", Status: "active", RegisteredAt: now, LastSeen: now},
+		{AgentID: "agent-b", Hostname: "globex-pc", Token: "tb", TenantID: "tenant-b", Status: "active", RegisteredAt: now, LastSeen: now},
+	} {
+		if err := store.RegisterAgent(ctx, a); err != nil {
+			t.Fatalf("RegisterAgent: %v", err)
+		}
+	}
+	upsert := func(serial, agentID, ip string) {
+		t.Helper()
+		dev := &storage.Device{AgentID: agentID}
+		dev.Serial, dev.IP, dev.LastSeen = serial, ip, now
+		if err := store.UpsertDevice(ctx, dev); err != nil {
+			t.Fatalf("UpsertDevice: %v", err)
+		}
+	}
+	upsert("SER-A1", "agent-a", "10.0.0.1")
+	upsert("SER-A2", "agent-a", "10.0.0.2")
+	upsert("SER-B1", "agent-b", "10.1.0.1")
+	if err := store.SaveMetrics(ctx, &storage.MetricsSnapshot{Serial: "SER-A1", AgentID: "agent-a", Timestamp: now, PageCount: 42}); err != nil {
+		t.Fatalf("SaveMetrics: %v", err)
+	}
+	upsert("SER-A1", "agent-a", "10.0.0.9")
+
+	fetch := func(user *storage.User, query string) (DeviceChangesResponse, int) {
+		t.Helper()
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/changes?"+query, nil)
+		rr := httptest.NewRecorder()
+		handleDeviceChanges(rr, InjectTestUser(req, user))
+		var resp DeviceChangesResponse
+		if rr.Code == http.StatusOK {
+			if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil {
+				t.Fatalf("decode: %v", err)
+			}
+		}
+		return resp, rr.Code
+	}
+
+	admin := NewTestAdminUser()
+	all, code := fetch(admin, "")
+	if code != http.StatusOK || len(all.Changes) != 4 || all.HasMore {
+		t.Fatalf("admin feed: code=%d %+v", code, all)
+	}
+	// SER-A1's two device changes collapse into the latest snapshot
+	first := all.Changes[0]
+	if first.Serial != "SER-A2" || first.Device == nil {
+		t.Errorf("first change = %+v", first)
+	}
+	var sawMetrics bool
+	for _, c := range all.Changes {
+		if c.Serial == "SER-A1" && c.Type == storage.DeviceChangeKindDevice && c.Device.IP != "10.0.0.9" {
+			t.Errorf("expected latest SER-A1 snapshot, got %+v", c.Device)
+		}
+		if c.Type == storage.DeviceChangeKindMetrics {
+			sawMetrics = c.Metrics != nil && c.Metrics.PageCount == 42
+		}
+	}
+	if !sawMetrics {
+		t.Error("expected metrics change with snapshot")
+	}
+
+	// Paging resumes from next_cursor
+	page, _ := fetch(admin, "limit=2")
+	if !page.HasMore || len(page.Changes) != 2 {
+		t.Fatalf("first page = %+v", page)
+	}
+	rest, _ := fetch(admin, fmt.Sprintf("cursor=%d", page.NextCursor))
+	if rest.HasMore || len(rest.Changes) != 3 || rest.NextCursor != all.NextCursor {
+		t.Errorf("second page = %+v", rest)
+	}
+
+	// Scoped users only see their tenants' agents
+	viewer := NewTestUser(storage.RoleViewer, "tenant-b")
+	scoped, _ := fetch(viewer, "")
+	if len(scoped.Changes) != 1 || scoped.Changes[0].Serial != "SER-B1" {
+		t.Errorf("scoped feed = %+v", scoped.Changes)
+	}
+	if _, code := fetch(viewer, "agent_id=agent-a"); code != http.StatusNotFound {
+		t.Errorf("foreign agent_id: code=%d", code)
+	}
+	if metrics, _ := fetch(admin, "types=metrics&agent_id=agent-a"); len(metrics.Changes) != 1 {
+		t.Errorf("metrics feed = %+v", metrics.Changes)
+	}
+
+	// Deletions are tombstones
+	cursor := all.NextCursor
+	if err := store.DeleteDevice(ctx, "SER-A2", true); err != nil {
+		t.Fatalf("DeleteDevice: %v", err)
+	}
+	deleted, _ := fetch(admin, fmt.Sprintf("cursor=%d", cursor))
+	if len(deleted.Changes) != 1 || deleted.Changes[0].Op != storage.DeviceChangeOpDelete || deleted.Changes[0].Device != nil {
+		t.Errorf("tombstone = %+v", deleted.Changes)
+	}
+
+	latest, _ := fetch(admin, "cursor=latest")
+	if len(latest.Changes) != 0 || latest.NextCursor != deleted.NextCursor {
+		t.Errorf("latest = %+v", latest)
+	}
+
+	// A cursor behind the retained history must resync
+	if _, err := store.DeleteDeviceChangesBefore(ctx, time.Now().Add(time.Hour)); err != nil {
+		t.Fatalf("DeleteDeviceChangesBefore: %v", err)
+	}
+	upsert("SER-B2", "agent-b", "10.1.0.2")
+	if _, code := fetch(admin, "cursor=1"); code != http.StatusGone {
+		t.Errorf("expired cursor: code=%d", code)
+	}
+	if _, code := fetch(admin, "types=toner"); code != http.StatusBadRequest {
+		t.Errorf("unknown type: code=%d", code)
+	}
+}
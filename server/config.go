@@ -32,6 +32,12 @@ type Config struct {
 	SMTP       SMTPConfig            `toml:"smtp"`
 	Releases   ReleasesConfig        `toml:"releases"`
 	SelfUpdate SelfUpdateConfig      `toml:"self_update"`
+	Ingest     IngestConfig          `toml:"ingest"`
+	MQTT       MQTTBridgeConfig      `toml:"mqtt"`
+	ScanMail   ScanMailConfig        `toml:"scan_mail"`
+	Archive    MetricsArchiveConfig  `toml:"metrics_archive"`
+	Prometheus PrometheusConfig      `toml:"prometheus"`
+	Vendor     VendorWebhooksConfig  `toml:"vendor_webhooks"`
 }
 
 // ServerConfig holds server-specific settings
@@ -46,6 +52,7 @@ type ServerConfig struct {
 	AutoApproveAgents   bool     `toml:"auto_approve_agents"`
 	AgentTimeoutMinutes int      `toml:"agent_timeout_minutes"`
 	SelfUpdateEnabled   bool     `toml:"self_update_enabled"`
+	PublicURL           string   `toml:"public_url"` // External base URL used for links in notifications (e.g. https://printmaster.example.com)
 }
 
 // ReleasesConfig tunes the GitHub release intake worker.
@@ -54,6 +61,79 @@ type ReleasesConfig struct {
 	PollIntervalMinutes int    `toml:"poll_interval_minutes"`
 	RetentionVersions   int    `toml:"retention_versions"` // 0 = disabled (keep all), N = keep N versions per component
 	IncludePrerelease   string `toml:"include_prerelease"` // "true", "false", or "" (auto-detect from build type)
+	ProxyURL            string `toml:"proxy_url"`          // HTTP(S) proxy for release fetches ("" = HTTPS_PROXY/HTTP_PROXY env)
+	ProxyUsername       string `toml:"proxy_username"`
+	ProxyPassword       string `toml:"proxy_password"`
+	Offline             bool   `toml:"offline"`    // Air-gapped: never contact GitHub, serve only imported bundles
+	ImportDir           string `toml:"import_dir"` // Offline mode: directory (e.g. USB mount) scanned for release bundles
+}
+
+// IngestConfig bounds concurrent processing of agent uploads. Each upload kind
+// (devices, metrics, meter reads) gets its own worker pool and queue.
+type IngestConfig struct {
+	Workers        int `toml:"workers"`          // Uploads of one kind processed concurrently
+	QueueSize      int `toml:"queue_size"`       // Uploads that may wait for a worker before agents get 429
+	MaxWaitSeconds int `toml:"max_wait_seconds"` // Longest an upload may wait for a worker
+}
+
+// MQTTBridgeConfig connects the server to the broker used by agents on the
+// MQTT transport. Agent messages go through the same handlers and ingest
+// queues as HTTP uploads.
+type MQTTBridgeConfig struct {
+	Enabled     bool   `toml:"enabled"`
+	Broker      string `toml:"broker"`    // tcp://, ssl://, ws:// or wss:// URL
+	ClientID    string `toml:"client_id"` // default "printmaster-server"
+	Username    string `toml:"username"`
+	Password    string `toml:"password"`
+	TopicPrefix string `toml:"topic_prefix"` // must match the agents' topic_prefix
+	QoS         int    `toml:"qos"`
+	// CleanSession drops the server's broker session on connect; leave false
+	// so messages published while the server was down are delivered
+	CleanSession       bool   `toml:"clean_session"`
+	CAPath             string `toml:"ca_path"`
+	CertPath           string `toml:"cert_path"` // client certificate for mutual TLS
+	KeyPath            string `toml:"key_path"`
+	InsecureSkipVerify bool   `toml:"insecure_skip_verify"`
+}
+
+// ScanMailConfig runs an SMTP sink that receives scan-to-email test messages
+// from MFPs. Received scans are tied to devices to record that their scan
+// path works.
+type ScanMailConfig struct {
+	Enabled         bool     `toml:"enabled"`
+	ListenAddr      string   `toml:"listen_addr"`      // default ":2525"
+	Hostname        string   `toml:"hostname"`         // name in the SMTP greeting (default: system hostname)
+	AllowedNetworks []string `toml:"allowed_networks"` // CIDRs or IPs allowed to deliver; empty = any
+	MaxMessageMB    int      `toml:"max_message_mb"`
+	RetentionDays   int      `toml:"retention_days"` // 0 = keep all
+}
+
+// VendorWebhooksConfig accepts signed webhooks from vendor cloud platforms
+// (HP Smart Device Services, Lexmark Cloud) at
+// /api/v1/integrations/webhooks/{source}/{tenant_id}. Secrets are generated
+// per tenant and source through the API, so a tenant is accepted only once
+// its secret exists.
+type VendorWebhooksConfig struct {
+	Enabled        bool `toml:"enabled"`
+	MaxSkewSeconds int  `toml:"max_skew_seconds"` // default 300
+	RetentionDays  int  `toml:"retention_days"`   // 0 = keep all
+}
+
+// MetricsArchiveConfig moves metrics older than MinAgeYears into monthly
+// Parquet files, keeping one summary row per device and day queryable.
+type MetricsArchiveConfig struct {
+	Enabled     bool   `toml:"enabled"`
+	MinAgeYears int    `toml:"min_age_years"` // default 3
+	Dir         string `toml:"dir"`           // default: <data dir>/archive/metrics
+	RestoreDays int    `toml:"restore_days"`  // how long a restored month stays at full resolution (default 7)
+}
+
+// PrometheusConfig controls the fleet metrics endpoint at /metrics. Scrapers
+// from AllowedNetworks need no credentials and see every tenant; others must
+// send an API token and see only their tenants.
+type PrometheusConfig struct {
+	Enabled         bool     `toml:"enabled"`
+	AllowedNetworks []string `toml:"allowed_networks"` // CIDRs or IPs; empty = token only
 }
 
 // SelfUpdateConfig exposes tweakable server auto-update controls.
@@ -74,6 +154,11 @@ type SecurityConfig struct {
 	PasswordRequireLower   bool `toml:"password_require_lower"`    // Require lowercase letter (default: false)
 	PasswordRequireNumber  bool `toml:"password_require_number"`   // Require number (default: false)
 	PasswordRequireSpecial bool `toml:"password_require_special"`  // Require special character (default: false)
+	SessionLifetimeHours   int  `toml:"session_lifetime_hours"`    // Absolute lifetime of a login session (default: 24)
+	SessionIdleMinutes     int  `toml:"session_idle_minutes"`      // End sessions idle this long, 0 = never (default: 0)
+	AgentTokenLifetimeDays int  `toml:"agent_token_lifetime_days"` // Lifetime of rotated agent tokens (default: 90)
+	RequireApprovals       bool `toml:"require_approvals"`         // Hold dangerous remote actions for a second admin (default: false)
+	ApprovalExpiryHours    int  `toml:"approval_expiry_hours"`     // Hours a pending approval stays open (default: 24)
 }
 
 // TLSConfigTOML holds TLS configuration from TOML
@@ -99,6 +184,9 @@ type SMTPConfig struct {
 	Pass       string `toml:"pass"`
 	From       string `toml:"from"`
 	EmailTheme string `toml:"email_theme"` // "dark", "light", or "auto" (default)
+	// AlertReplyTo is the Reply-To of alert emails. Replies delivered to the
+	// scan mail sink acknowledge the alert; empty disables reply handling.
+	AlertReplyTo string `toml:"alert_reply_to"`
 }
 
 // LetsEncryptConfig holds Let's Encrypt specific settings
@@ -132,6 +220,11 @@ func DefaultConfig() *Config {
 			PasswordRequireLower:   false,
 			PasswordRequireNumber:  false,
 			PasswordRequireSpecial: false,
+			SessionLifetimeHours:   24, // Log in again once a day
+			SessionIdleMinutes:     0,  // No idle timeout
+			AgentTokenLifetimeDays: defaultAgentTokenLifetimeDays,
+			RequireApprovals:       false,
+			ApprovalExpiryHours:    defaultApprovalExpiryHours,
 		},
 		TLS: TLSConfigTOML{
 			Mode:   "self-signed",
@@ -170,6 +263,31 @@ func DefaultConfig() *Config {
 			MaxArtifacts:         12,
 			CheckIntervalMinutes: 360,
 		},
+		Ingest: IngestConfig{
+			Workers:        4,
+			QueueSize:      100,
+			MaxWaitSeconds: 30,
+		},
+		MQTT: MQTTBridgeConfig{
+			TopicPrefix: "printmaster",
+			QoS:         1,
+		},
+		ScanMail: ScanMailConfig{
+			ListenAddr:    ":2525",
+			MaxMessageMB:  25,
+			RetentionDays: 90,
+		},
+		Archive: MetricsArchiveConfig{
+			MinAgeYears: 3,
+			RestoreDays: 7,
+		},
+		Prometheus: PrometheusConfig{
+			Enabled: true,
+		},
+		Vendor: VendorWebhooksConfig{
+			MaxSkewSeconds: 300,
+			RetentionDays:  90,
+		},
 	}
 }
 
@@ -242,6 +360,10 @@ func applyEnvOverrides(cfg *Config, tracker *ConfigSourceTracker) {
 		cfg.Server.SelfUpdateEnabled = val == "true" || val == "1"
 		tracker.EnvKeys["server.self_update_enabled"] = true
 	}
+	if val := os.Getenv("SERVER_PUBLIC_URL"); val != "" {
+		cfg.Server.PublicURL = val
+		tracker.EnvKeys["server.public_url"] = true
+	}
 	if val := os.Getenv("RELEASES_MAX_RELEASES"); val != "" {
 		var v int
 		if _, err := fmt.Sscanf(val, "%d", &v); err == nil {
@@ -267,6 +389,26 @@ func applyEnvOverrides(cfg *Config, tracker *ConfigSourceTracker) {
 		cfg.Releases.IncludePrerelease = val
 		tracker.EnvKeys["releases.include_prerelease"] = true
 	}
+	if val := os.Getenv("RELEASES_PROXY_URL"); val != "" {
+		cfg.Releases.ProxyURL = val
+		tracker.EnvKeys["releases.proxy_url"] = true
+	}
+	if val := os.Getenv("RELEASES_PROXY_USERNAME"); val != "" {
+		cfg.Releases.ProxyUsername = val
+		tracker.EnvKeys["releases.proxy_username"] = true
+	}
+	if val := os.Getenv("RELEASES_PROXY_PASSWORD"); val != "" {
+		cfg.Releases.ProxyPassword = val
+		tracker.EnvKeys["releases.proxy_password"] = true
+	}
+	if val := os.Getenv("RELEASES_OFFLINE"); val != "" {
+		cfg.Releases.Offline = val == "true" || val == "1"
+		tracker.EnvKeys["releases.offline"] = true
+	}
+	if val := os.Getenv("RELEASES_IMPORT_DIR"); val != "" {
+		cfg.Releases.ImportDir = val
+		tracker.EnvKeys["releases.import_dir"] = true
+	}
 	if val := os.Getenv("SELF_UPDATE_CHANNEL"); val != "" {
 		cfg.SelfUpdate.Channel = val
 		tracker.EnvKeys["self_update.channel"] = true
@@ -285,6 +427,78 @@ func applyEnvOverrides(cfg *Config, tracker *ConfigSourceTracker) {
 			tracker.EnvKeys["self_update.check_interval_minutes"] = true
 		}
 	}
+	if val := os.Getenv("INGEST_WORKERS"); val != "" {
+		var v int
+		if _, err := fmt.Sscanf(val, "%d", &v); err == nil && v > 0 {
+			cfg.Ingest.Workers = v
+			tracker.EnvKeys["ingest.workers"] = true
+		}
+	}
+	if val := os.Getenv("INGEST_QUEUE_SIZE"); val != "" {
+		var v int
+		if _, err := fmt.Sscanf(val, "%d", &v); err == nil && v > 0 {
+			cfg.Ingest.QueueSize = v
+			tracker.EnvKeys["ingest.queue_size"] = true
+		}
+	}
+	if val := os.Getenv("INGEST_MAX_WAIT_SECONDS"); val != "" {
+		var v int
+		if _, err := fmt.Sscanf(val, "%d", &v); err == nil && v > 0 {
+			cfg.Ingest.MaxWaitSeconds = v
+			tracker.EnvKeys["ingest.max_wait_seconds"] = true
+		}
+	}
+	if val := os.Getenv("MQTT_BRIDGE_ENABLED"); val != "" {
+		cfg.MQTT.Enabled = val == "true" || val == "1"
+		tracker.EnvKeys["mqtt.enabled"] = true
+	}
+	if val := os.Getenv("MQTT_BROKER"); val != "" {
+		cfg.MQTT.Broker = val
+		tracker.EnvKeys["mqtt.broker"] = true
+	}
+	if val := os.Getenv("MQTT_USERNAME"); val != "" {
+		cfg.MQTT.Username = val
+		tracker.EnvKeys["mqtt.username"] = true
+	}
+	if val := os.Getenv("MQTT_PASSWORD"); val != "" {
+		cfg.MQTT.Password = val
+		tracker.EnvKeys["mqtt.password"] = true
+	}
+	if val := os.Getenv("SCAN_MAIL_ENABLED"); val != "" {
+		cfg.ScanMail.Enabled = val == "true" || val == "1"
+		tracker.EnvKeys["scan_mail.enabled"] = true
+	}
+	if val := os.Getenv("SCAN_MAIL_LISTEN"); val != "" {
+		cfg.ScanMail.ListenAddr = val
+		tracker.EnvKeys["scan_mail.listen_addr"] = true
+	}
+	if val := os.Getenv("VENDOR_WEBHOOKS_ENABLED"); val != "" {
+		cfg.Vendor.Enabled = val == "true" || val == "1"
+		tracker.EnvKeys["vendor_webhooks.enabled"] = true
+	}
+	if val := os.Getenv("METRICS_ARCHIVE_ENABLED"); val != "" {
+		cfg.Archive.Enabled = val == "true" || val == "1"
+		tracker.EnvKeys["metrics_archive.enabled"] = true
+	}
+	if val := os.Getenv("METRICS_ARCHIVE_MIN_AGE_YEARS"); val != "" {
+		var years int
+		if _, err := fmt.Sscanf(val, "%d", &years); err == nil && years > 0 {
+			cfg.Archive.MinAgeYears = years
+			tracker.EnvKeys["metrics_archive.min_age_years"] = true
+		}
+	}
+	if val := os.Getenv("METRICS_ARCHIVE_DIR"); val != "" {
+		cfg.Archive.Dir = val
+		tracker.EnvKeys["metrics_archive.dir"] = true
+	}
+	if val := os.Getenv("PROMETHEUS_ENABLED"); val != "" {
+		cfg.Prometheus.Enabled = val == "true" || val == "1"
+		tracker.EnvKeys["prometheus.enabled"] = true
+	}
+	if val := os.Getenv("PROMETHEUS_ALLOWED_NETWORKS"); val != "" {
+		cfg.Prometheus.AllowedNetworks = parseStringListEnv(val)
+		tracker.EnvKeys["prometheus.allowed_networks"] = true
+	}
 	if val := os.Getenv("TLS_MODE"); val != "" {
 		cfg.TLS.Mode = val
 		tracker.EnvKeys["tls.mode"] = true
@@ -342,6 +556,10 @@ func applyEnvOverrides(cfg *Config, tracker *ConfigSourceTracker) {
 		cfg.SMTP.EmailTheme = val
 		tracker.EnvKeys["smtp.email_theme"] = true
 	}
+	if val := os.Getenv("SMTP_ALERT_REPLY_TO"); val != "" {
+		cfg.SMTP.AlertReplyTo = val
+		tracker.EnvKeys["smtp.alert_reply_to"] = true
+	}
 
 	// Logging env overrides with tracking (check prefixed first, then generic)
 	if val := os.Getenv("SERVER_LOG_LEVEL"); val != "" {
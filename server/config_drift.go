@@ -0,0 +1,361 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	pmsettings "printmaster/common/settings"
+	wscommon "printmaster/common/ws"
+	authz "printmaster/server/authz"
+	serversettings "printmaster/server/settings"
+	"printmaster/server/storage"
+)
+
+// syncSettingsCommand asks a WebSocket-connected agent to send an HTTP
+// heartbeat right away, which is how it receives a re-enforced policy.
+const syncSettingsCommand = "sync_settings"
+
+// recordAgentConfigDrift compares the settings an agent reported in a
+// heartbeat with the policy snapshot resolved for it, stores the result and
+// logs a history event whenever the agent starts or stops drifting. It
+// returns the stored state, or nil when nothing could be compared.
+func recordAgentConfigDrift(ctx context.Context, agent *storage.Agent, snapshot serversettings.AgentSnapshot, reported *pmsettings.Settings, reportedVersion string) *storage.AgentConfigDrift {
+	if agent == nil || reported == nil || snapshot.Version == "" {
+		return nil
+	}
+	fields, err := serversettings.DiffAgentSettings(snapshot.Settings, *reported)
+	if err != nil {
+		logWarn("Failed to compare agent settings", "agent_id", agent.AgentID, "error", err)
+		return nil
+	}
+	prev, err := serverStore.GetAgentConfigDrift(ctx, agent.AgentID)
+	if err != nil {
+		logWarn("Failed to load agent config drift", "agent_id", agent.AgentID, "error", err)
+		return nil
+	}
+
+	now := time.Now().UTC()
+	d := &storage.AgentConfigDrift{
+		AgentID:         agent.AgentID,
+		TenantID:        agent.TenantID,
+		ExpectedVersion: snapshot.Version,
+		ReportedVersion: reportedVersion,
+		Drifted:         len(fields) > 0,
+		Fields:          fields,
+		CheckedAt:       now,
+	}
+	wasDrifted := prev != nil && prev.Drifted
+	if prev != nil {
+		d.EnforceRequestedAt = prev.EnforceRequestedAt
+		d.EnforceRequestedBy = prev.EnforceRequestedBy
+		d.LastEnforcedAt = prev.LastEnforcedAt
+		if wasDrifted {
+			d.DriftSince = prev.DriftSince
+		}
+	}
+	if d.Drifted && d.DriftSince == nil {
+		d.DriftSince = &now
+	}
+	if err := serverStore.SaveAgentConfigDrift(ctx, d); err != nil {
+		logWarn("Failed to save agent config drift", "agent_id", agent.AgentID, "error", err)
+		return nil
+	}
+
+	event := ""
+	switch {
+	case d.Drifted && (!wasDrifted || !sameDriftPaths(prev.Fields, fields)):
+		event = storage.ConfigDriftEventDetected
+		logWarn("Agent settings drift from policy", "agent_id", agent.AgentID, "fields", driftPaths(fields))
+	case !d.Drifted && wasDrifted:
+		event = storage.ConfigDriftEventResolved
+		logInfo("Agent settings back in line with policy", "agent_id", agent.AgentID)
+	}
+	if event != "" {
+		if err := serverStore.AddAgentConfigDriftEvent(ctx, &storage.AgentConfigDriftEvent{
+			AgentID:         agent.AgentID,
+			TenantID:        agent.TenantID,
+			Event:           event,
+			Fields:          fields,
+			ExpectedVersion: snapshot.Version,
+			ReportedVersion: reportedVersion,
+			CreatedAt:       now,
+		}); err != nil {
+			logWarn("Failed to record config drift event", "agent_id", agent.AgentID, "error", err)
+		}
+		sseHub.Broadcast(SSEEvent{
+			Type: "agent_config_drift",
+			Data: map[string]interface{}{
+				"agent_id": agent.AgentID,
+				"drifted":  d.Drifted,
+				"fields":   driftPaths(fields),
+			},
+		})
+	}
+	return d
+}
+
+// markConfigDriftEnforced clears a pending re-enforcement once the policy
+// snapshot has been handed to the agent.
+func markConfigDriftEnforced(ctx context.Context, d *storage.AgentConfigDrift) {
+	now := time.Now().UTC()
+	d.EnforceRequestedAt = nil
+	d.LastEnforcedAt = &now
+	if err := serverStore.SaveAgentConfigDrift(ctx, d); err != nil {
+		logWarn("Failed to clear config drift enforcement", "agent_id", d.AgentID, "error", err)
+	}
+}
+
+// decodeReportedSettings reads the effective_settings an agent sends with a
+// WebSocket heartbeat. Older agents do not send them.
+func decodeReportedSettings(data map[string]interface{}) *pmsettings.Settings {
+	raw, ok := data["effective_settings"]
+	if !ok || raw == nil {
+		return nil
+	}
+	encoded, err := json.Marshal(raw)
+	if err != nil {
+		return nil
+	}
+	var cfg pmsettings.Settings
+	if err := json.Unmarshal(encoded, &cfg); err != nil {
+		return nil
+	}
+	return &cfg
+}
+
+// checkWSAgentConfigDrift records drift for a WebSocket heartbeat. Settings
+// are only delivered over HTTP heartbeats, so a pending re-enforcement asks
+// the agent to send one.
+func checkWSAgentConfigDrift(ctx context.Context, conn *wscommon.Conn, agent *storage.Agent, data map[string]interface{}) {
+	reported := decodeReportedSettings(data)
+	if reported == nil || settingsResolver == nil {
+		return
+	}
+	snapshot, err := serversettings.BuildAgentSnapshot(ctx, settingsResolver, agent.TenantID, agent.AgentID)
+	if err != nil {
+		logWarn("Failed to build settings snapshot", "agent_id", agent.AgentID, "error", err)
+		return
+	}
+	d := recordAgentConfigDrift(ctx, agent, snapshot, reported, wsStringField(data, "settings_version"))
+	if d != nil && d.EnforceRequestedAt != nil {
+		if err := writeAgentCommand(conn, syncSettingsCommand, nil); err != nil {
+			logWarn("Failed to ask agent to sync settings", "agent_id", agent.AgentID, "error", err)
+		}
+	}
+}
+
+func writeAgentCommand(conn *wscommon.Conn, command string, data map[string]interface{}) error {
+	msg := wscommon.Message{
+		Type:      wscommon.MessageTypeCommand,
+		Timestamp: time.Now(),
+		Data:      map[string]interface{}{"command": command},
+	}
+	for k, v := range data {
+		msg.Data[k] = v
+	}
+	payload, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	return conn.WriteRaw(payload, 10*time.Second)
+}
+
+func sameDriftPaths(a, b []storage.SettingsDriftField) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i].Path != b[i].Path {
+			return false
+		}
+	}
+	return true
+}
+
+func driftPaths(fields []storage.SettingsDriftField) []string {
+	paths := make([]string, len(fields))
+	for i, f := range fields {
+		paths[i] = f.Path
+	}
+	return paths
+}
+
+// ============================================================
+// Config drift API
+// ============================================================
+
+// handleConfigDriftList lists agents whose reported settings differ from
+// their policy. all=true includes agents that are in line.
+func handleConfigDriftList(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "GET only", http.StatusMethodNotAllowed)
+		return
+	}
+	if !authorizeOrReject(w, r, authz.ActionSettingsFleetRead, authz.ResourceRef{}) {
+		return
+	}
+	principal := getPrincipal(r)
+	var tenantIDs []string
+	if principal != nil && !principal.IsAdmin() {
+		tenantIDs = principal.AllowedTenantIDs()
+		if len(tenantIDs) == 0 {
+			writeConfigDrift(w, nil)
+			return
+		}
+	}
+	if tid := strings.TrimSpace(r.URL.Query().Get("tenant_id")); tid != "" {
+		if !authorizeOrReject(w, r, authz.ActionSettingsFleetRead, authz.ResourceRef{TenantIDs: []string{tid}}) {
+			return
+		}
+		tenantIDs = []string{tid}
+	}
+	driftedOnly := r.URL.Query().Get("all") != "true"
+	states, err := serverStore.ListAgentConfigDrift(r.Context(), tenantIDs, driftedOnly)
+	if err != nil {
+		logError("Failed to list agent config drift", "error", err)
+		http.Error(w, "failed to list config drift", http.StatusInternalServerError)
+		return
+	}
+	writeConfigDrift(w, states)
+}
+
+// handleConfigDriftAgent returns one agent's drift state and history, or
+// re-enforces its policy (POST .../{agentID}/enforce).
+func handleConfigDriftAgent(w http.ResponseWriter, r *http.Request) {
+	rest := strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/v1/config-drift/"), "/")
+	agentID, action, _ := strings.Cut(rest, "/")
+	if agentID == "" || (action != "" && action != "enforce") {
+		http.Error(w, "not found", http.StatusNotFound)
+		return
+	}
+	ctx := r.Context()
+	agent, err := serverStore.GetAgent(ctx, agentID)
+	if err != nil || agent == nil {
+		http.Error(w, "agent not found", http.StatusNotFound)
+		return
+	}
+	resource := authz.ResourceRef{TenantIDs: []string{agent.TenantID}}
+
+	if action == "enforce" {
+		if r.Method != http.MethodPost {
+			http.Error(w, "POST only", http.StatusMethodNotAllowed)
+			return
+		}
+		if !authorizeOrReject(w, r, authz.ActionSettingsFleetWrite, resource) {
+			return
+		}
+		enforceAgentConfig(w, r, agent)
+		return
+	}
+
+	if r.Method != http.MethodGet {
+		http.Error(w, "GET only", http.StatusMethodNotAllowed)
+		return
+	}
+	if !authorizeOrReject(w, r, authz.ActionSettingsFleetRead, resource) {
+		return
+	}
+	state, err := serverStore.GetAgentConfigDrift(ctx, agentID)
+	if err != nil {
+		logError("Failed to load agent config drift", "agent_id", agentID, "error", err)
+		http.Error(w, "failed to load config drift", http.StatusInternalServerError)
+		return
+	}
+	history, err := serverStore.ListAgentConfigDriftEvents(ctx, agentID, 100)
+	if err != nil {
+		logError("Failed to load config drift history", "agent_id", agentID, "error", err)
+		http.Error(w, "failed to load config drift history", http.StatusInternalServerError)
+		return
+	}
+	if history == nil {
+		history = []*storage.AgentConfigDriftEvent{}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"state":   state,
+		"history": history,
+	})
+}
+
+// enforceAgentConfig flags the agent so its next HTTP heartbeat returns the
+// full policy snapshot with settings_enforce set, which makes the agent
+// re-apply it even when its settings version already matches. Agents on a
+// WebSocket are asked to send that heartbeat now.
+func enforceAgentConfig(w http.ResponseWriter, r *http.Request, agent *storage.Agent) {
+	ctx := r.Context()
+	state, err := serverStore.GetAgentConfigDrift(ctx, agent.AgentID)
+	if err != nil {
+		logError("Failed to load agent config drift", "agent_id", agent.AgentID, "error", err)
+		http.Error(w, "failed to load config drift", http.StatusInternalServerError)
+		return
+	}
+	if state == nil {
+		http.Error(w, "agent has not reported its settings yet", http.StatusConflict)
+		return
+	}
+
+	_, _, actorName, _ := auditActorFromPrincipal(r)
+	now := time.Now().UTC()
+	state.EnforceRequestedAt = &now
+	state.EnforceRequestedBy = actorName
+	if err := serverStore.SaveAgentConfigDrift(ctx, state); err != nil {
+		logError("Failed to save config drift enforcement", "agent_id", agent.AgentID, "error", err)
+		http.Error(w, "failed to request enforcement", http.StatusInternalServerError)
+		return
+	}
+	if err := serverStore.AddAgentConfigDriftEvent(ctx, &storage.AgentConfigDriftEvent{
+		AgentID:         agent.AgentID,
+		TenantID:        agent.TenantID,
+		Event:           storage.ConfigDriftEventEnforced,
+		Fields:          state.Fields,
+		ExpectedVersion: state.ExpectedVersion,
+		ReportedVersion: state.ReportedVersion,
+		Actor:           actorName,
+		CreatedAt:       now,
+	}); err != nil {
+		logWarn("Failed to record config drift event", "agent_id", agent.AgentID, "error", err)
+	}
+
+	delivered := false
+	if conn, ok := getAgentWSConnection(agent.AgentID); ok {
+		if err := writeAgentCommand(conn, syncSettingsCommand, nil); err != nil {
+			logWarn("Failed to ask agent to sync settings", "agent_id", agent.AgentID, "error", err)
+		} else {
+			delivered = true
+		}
+	}
+
+	actorType, actorID, _, actorTenant := auditActorFromPrincipal(r)
+	logInfo("Agent settings re-enforcement requested", "agent_id", agent.AgentID, "actor", actorName)
+	logAuditEntry(ctx, &storage.AuditEntry{
+		ActorType:  actorType,
+		ActorID:    actorID,
+		ActorName:  actorName,
+		TenantID:   actorTenant,
+		Action:     "agent.settings.enforce",
+		TargetType: "agent",
+		TargetID:   agent.AgentID,
+		Details:    fmt.Sprintf("Re-enforce settings policy on %s (%d drifted fields)", displayNameForAgent(agent), len(state.Fields)),
+		IPAddress:  extractClientIP(r),
+		UserAgent:  r.Header.Get("User-Agent"),
+	})
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"state":     state,
+		"immediate": delivered,
+	})
+}
+
+func writeConfigDrift(w http.ResponseWriter, states []*storage.AgentConfigDrift) {
+	if states == nil {
+		states = []*storage.AgentConfigDrift{}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(states)
+}
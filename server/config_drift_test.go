@@ -0,0 +1,126 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	pmsettings "printmaster/common/settings"
+	serversettings "printmaster/server/settings"
+	"printmaster/server/storage"
+)
+
+func TestConfigDriftDetectAndEnforce(t *testing.T) {
+	store := SetupTestStore(t)
+	ctx := context.Background()
+	if err := store.CreateTenant(ctx, &storage.Tenant{ID: "tenant-a", Name: "Acme"}); err != nil {
+		t.Fatalf("CreateTenant: %v", err)
+	}
+	agent := &storage.Agent{AgentID: "agent-a", Hostname: "a", Token: "ta", TenantID: "tenant-a", Status: "active", RegisteredAt: time.Now(), LastSeen: time.Now()}
+	if err := store.RegisterAgent(ctx, agent); err != nil {
+		t.Fatalf("RegisterAgent: %v", err)
+	}
+	var err error
+	settingsResolver, err = serversettings.NewResolver(store)
+	if err != nil {
+		t.Fatalf("NewResolver: %v", err)
+	}
+	t.Cleanup(func() { settingsResolver = nil })
+	snapshot, err := serversettings.BuildAgentSnapshot(ctx, settingsResolver, agent.TenantID, agent.AgentID)
+	if err != nil {
+		t.Fatalf("BuildAgentSnapshot: %v", err)
+	}
+
+	heartbeat := func(effective pmsettings.Settings) map[string]interface{} {
+		raw, _ := json.Marshal(map[string]interface{}{
+			"agent_id":           agent.AgentID,
+			"status":             "active",
+			"settings_version":   snapshot.Version,
+			"effective_settings": effective,
+		})
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/agents/heartbeat", bytes.NewReader(raw))
+		req = req.WithContext(context.WithValue(req.Context(), agentContextKey, agent))
+		rr := httptest.NewRecorder()
+		handleAgentHeartbeat(rr, req)
+		if rr.Code != http.StatusOK {
+			t.Fatalf("heartbeat: expected 200, got %d", rr.Code)
+		}
+		var resp map[string]interface{}
+		json.Unmarshal(rr.Body.Bytes(), &resp)
+		return resp
+	}
+
+	drifted := snapshot.Settings
+	drifted.Discovery.SNMPEnabled = !drifted.Discovery.SNMPEnabled
+	drifted.Discovery.RangesText = "10.0.0.0/24" // per-agent, never drift
+	drifted.Logging.Level = "debug"              // agent-local, never drift
+	if resp := heartbeat(drifted); resp["settings_snapshot"] != nil {
+		t.Fatalf("snapshot must not be re-sent without enforcement: %v", resp)
+	}
+
+	operator := NewTestUser(storage.RoleOperator, "tenant-a")
+	req := InjectTestUser(httptest.NewRequest(http.MethodGet, "/api/v1/config-drift", nil), operator)
+	rr := httptest.NewRecorder()
+	handleConfigDriftList(rr, req)
+	var states []storage.AgentConfigDrift
+	if err := json.Unmarshal(rr.Body.Bytes(), &states); err != nil || len(states) != 1 {
+		t.Fatalf("list: %d states, %v: %s", len(states), err, rr.Body.String())
+	}
+	if got := states[0].Fields; len(got) != 1 || got[0].Path != "discovery.snmp_enabled" {
+		t.Fatalf("unexpected drift fields: %+v", got)
+	}
+
+	other := NewTestUser(storage.RoleOperator, "tenant-b")
+	req = InjectTestUser(httptest.NewRequest(http.MethodPost, "/api/v1/config-drift/agent-a/enforce", nil), other)
+	rr = httptest.NewRecorder()
+	handleConfigDriftAgent(rr, req)
+	if rr.Code != http.StatusForbidden {
+		t.Fatalf("enforce across tenants: expected 403, got %d", rr.Code)
+	}
+	req = InjectTestUser(httptest.NewRequest(http.MethodPost, "/api/v1/config-drift/agent-a/enforce", nil), NewTestUser(storage.RoleAdmin))
+	rr = httptest.NewRecorder()
+	handleConfigDriftAgent(rr, req)
+	if rr.Code != http.StatusAccepted {
+		t.Fatalf("enforce: expected 202, got %d: %s", rr.Code, rr.Body.String())
+	}
+
+	resp := heartbeat(drifted)
+	if resp["settings_enforce"] != true || resp["settings_snapshot"] == nil {
+		t.Fatalf("expected enforced snapshot in heartbeat response: %v", resp)
+	}
+	if resp := heartbeat(drifted); resp["settings_enforce"] != nil {
+		t.Fatalf("enforcement must be delivered once: %v", resp)
+	}
+	heartbeat(snapshot.Settings)
+
+	req = InjectTestUser(httptest.NewRequest(http.MethodGet, "/api/v1/config-drift/agent-a", nil), operator)
+	rr = httptest.NewRecorder()
+	handleConfigDriftAgent(rr, req)
+	var detail struct {
+		State   storage.AgentConfigDrift        `json:"state"`
+		History []storage.AgentConfigDriftEvent `json:"history"`
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &detail); err != nil {
+		t.Fatalf("decode detail: %v", err)
+	}
+	if detail.State.Drifted || detail.State.LastEnforcedAt == nil || detail.State.EnforceRequestedAt != nil {
+		t.Fatalf("unexpected state after resolve: %+v", detail.State)
+	}
+	var events []string
+	for _, e := range detail.History {
+		events = append(events, e.Event)
+	}
+	want := []string{storage.ConfigDriftEventResolved, storage.ConfigDriftEventEnforced, storage.ConfigDriftEventDetected}
+	if len(events) != len(want) {
+		t.Fatalf("history = %v, want %v", events, want)
+	}
+	for i := range want {
+		if events[i] != want[i] {
+			t.Fatalf("history = %v, want %v", events, want)
+		}
+	}
+}
@@ -0,0 +1,325 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	authz "printmaster/server/authz"
+	"printmaster/server/reports"
+	"printmaster/server/storage"
+)
+
+// maxParseSamplesPerBatch caps the samples accepted in one upload.
+const maxParseSamplesPerBatch = 200
+
+// parseSampleRetention is how long a sample is kept after it was captured.
+const parseSampleRetention = 30 * 24 * time.Hour
+
+// parseSampleUpload is one sample in an agent's parse-samples batch.
+type parseSampleUpload struct {
+	IP           string            `json:"ip"`
+	Serial       string            `json:"serial"`
+	Manufacturer string            `json:"manufacturer"`
+	Model        string            `json:"model"`
+	SysObjectID  string            `json:"sys_object_id"`
+	Missing      []string          `json:"missing"`
+	Steps        []string          `json:"steps"`
+	RawPDUs      []json.RawMessage `json:"raw_pdus"`
+	CapturedAt   string            `json:"captured_at"`
+}
+
+// handleParseSamplesBatch stores parse-debug samples an agent uploads for
+// devices its parser could not fully read.
+func handleParseSamplesBatch(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "POST only", http.StatusMethodNotAllowed)
+		return
+	}
+	var req struct {
+		AgentID string              `json:"agent_id"`
+		Samples []parseSampleUpload `json:"samples"`
+	}
+	if err := decodeJSONBody(r, &req); err != nil {
+		logWarn("Invalid JSON in parse samples batch", "error", err)
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+	if len(req.Samples) > maxParseSamplesPerBatch {
+		req.Samples = req.Samples[:maxParseSamplesPerBatch]
+	}
+
+	agent := r.Context().Value(agentContextKey).(*storage.Agent)
+	ctx := r.Context()
+	stored := 0
+	for _, s := range req.Samples {
+		ip := strings.TrimSpace(s.IP)
+		if ip == "" {
+			continue
+		}
+		pdus := s.RawPDUs
+		if len(pdus) > storage.MaxParseSamplePDUs {
+			pdus = pdus[:storage.MaxParseSamplePDUs]
+		}
+		raw, err := json.Marshal(pdus)
+		if err != nil {
+			continue
+		}
+		capturedAt, err := time.Parse(time.RFC3339, s.CapturedAt)
+		if err != nil || capturedAt.After(time.Now().Add(time.Hour)) {
+			capturedAt = time.Now().UTC()
+		}
+		sample := &storage.ParseSample{
+			AgentID:      agent.AgentID,
+			TenantID:     agent.TenantID,
+			IP:           ip,
+			Serial:       strings.TrimSpace(s.Serial),
+			Manufacturer: strings.TrimSpace(s.Manufacturer),
+			Model:        strings.TrimSpace(s.Model),
+			SysObjectID:  strings.Trim(strings.TrimSpace(s.SysObjectID), "."),
+			Missing:      s.Missing,
+			Steps:        s.Steps,
+			RawPDUs:      raw,
+			PDUCount:     len(pdus),
+			CapturedAt:   capturedAt,
+		}
+		if err := serverStore.SaveParseSample(ctx, sample); err != nil {
+			logError("Failed to store parse sample", "agent_id", agent.AgentID, "ip", ip, "error", err)
+			continue
+		}
+		stored++
+	}
+
+	logDebug("Parse samples batch received", "agent_id", agent.AgentID, "received", len(req.Samples), "stored", stored)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"stored": stored})
+}
+
+// dataQualityScope limits the dashboard to the caller's tenants. ok is false
+// when the caller has no tenant access at all.
+func dataQualityScope(ctx context.Context, r *http.Request) (report *storage.ReportDefinition, ok bool, err error) {
+	report = &storage.ReportDefinition{Type: storage.ReportTypeDataQuality}
+	principal := getPrincipal(r)
+	if principal == nil || principal.IsAdmin() {
+		return report, true, nil
+	}
+	report.TenantIDs = principal.AllowedTenantIDs()
+	if len(report.TenantIDs) == 0 {
+		return report, false, nil
+	}
+	scope, _ := tenantScope(principal)
+	agents, err := serverStore.ListAgents(ctx)
+	if err != nil {
+		return nil, false, err
+	}
+	for _, a := range agents {
+		if tenantAllowed(scope, a.TenantID) {
+			report.AgentIDs = append(report.AgentIDs, a.AgentID)
+		}
+	}
+	return report, len(report.AgentIDs) > 0, nil
+}
+
+// handleDataQuality serves the data quality dashboard:
+//
+//	GET /api/v1/data-quality                    gaps grouped by manufacturer and sysObjectID
+//	GET /api/v1/data-quality/devices?manufacturer=&sys_object_id=&missing=
+//	GET /api/v1/data-quality/samples?manufacturer=&sys_object_id=&missing=&limit=
+//	GET /api/v1/data-quality/samples/{id}       sample with raw PDUs
+func handleDataQuality(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "GET only", http.StatusMethodNotAllowed)
+		return
+	}
+	if !authorizeOrReject(w, r, authz.ActionDevicesRead, authz.ResourceRef{}) {
+		return
+	}
+	ctx := r.Context()
+	report, ok, err := dataQualityScope(ctx, r)
+	if err != nil {
+		logError("Failed to resolve data quality scope", "error", err)
+		http.Error(w, "failed to load data quality", http.StatusInternalServerError)
+		return
+	}
+
+	rest := strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/v1/data-quality"), "/")
+	switch {
+	case rest == "":
+		writeDataQualitySummary(w, ctx, report, ok)
+	case rest == "devices":
+		writeDataQualityDevices(w, r, report, ok)
+	case rest == "samples":
+		writeParseSamples(w, r, report, ok)
+	case strings.HasPrefix(rest, "samples/"):
+		id, err := strconv.ParseInt(strings.TrimPrefix(rest, "samples/"), 10, 64)
+		if err != nil {
+			http.Error(w, "invalid sample id", http.StatusBadRequest)
+			return
+		}
+		writeParseSample(w, r, id, report, ok)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func writeDataQualitySummary(w http.ResponseWriter, ctx context.Context, report *storage.ReportDefinition, ok bool) {
+	resp := map[string]interface{}{
+		"summary":      map[string]any{"total_devices": 0, "affected_devices": 0},
+		"groups":       []map[string]any{},
+		"generated_at": time.Now().UTC(),
+	}
+	if ok {
+		result, err := reports.NewGenerator(&ReportStore{store: serverStore}).Generate(ctx, reports.GenerateParams{Report: report})
+		if err != nil {
+			logError("Failed to build data quality summary", "error", err)
+			http.Error(w, "failed to load data quality", http.StatusInternalServerError)
+			return
+		}
+		resp["summary"] = result.Summary
+		resp["groups"] = result.Rows
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+// dataQualityDevice is a device row in the group drill-down.
+type dataQualityDevice struct {
+	Serial       string   `json:"serial"`
+	IP           string   `json:"ip"`
+	AgentID      string   `json:"agent_id"`
+	Manufacturer string   `json:"manufacturer"`
+	Model        string   `json:"model"`
+	SysObjectID  string   `json:"sys_object_id,omitempty"`
+	Missing      []string `json:"missing"`
+}
+
+func writeDataQualityDevices(w http.ResponseWriter, r *http.Request, report *storage.ReportDefinition, ok bool) {
+	ctx := r.Context()
+	q := r.URL.Query()
+	manufacturer := strings.TrimSpace(q.Get("manufacturer"))
+	sysObjectID := strings.Trim(strings.TrimSpace(q.Get("sys_object_id")), ".")
+	missing := strings.TrimSpace(q.Get("missing"))
+
+	out := []dataQualityDevice{}
+	if ok {
+		rs := &ReportStore{store: serverStore}
+		devices, err := rs.ListAllDevices(ctx)
+		if err != nil {
+			logError("Failed to list devices for data quality", "error", err)
+			http.Error(w, "failed to list devices", http.StatusInternalServerError)
+			return
+		}
+		agents := make(map[string]bool, len(report.AgentIDs))
+		for _, id := range report.AgentIDs {
+			agents[id] = true
+		}
+		for _, d := range devices {
+			if d == nil || (len(agents) > 0 && !agents[d.AgentID]) {
+				continue
+			}
+			if manufacturer != "" && !strings.EqualFold(reports.ManufacturerLabel(d.Manufacturer), manufacturer) {
+				continue
+			}
+			if q.Has("sys_object_id") && reports.DeviceSysObjectID(d) != sysObjectID {
+				continue
+			}
+			m, _ := serverStore.GetLatestMetrics(ctx, d.Serial)
+			gaps := reports.DeviceParseGaps(d, m)
+			if len(gaps) == 0 || (missing != "" && !slices.Contains(gaps, missing)) {
+				continue
+			}
+			out = append(out, dataQualityDevice{
+				Serial:       d.Serial,
+				IP:           d.IP,
+				AgentID:      d.AgentID,
+				Manufacturer: d.Manufacturer,
+				Model:        d.Model,
+				SysObjectID:  reports.DeviceSysObjectID(d),
+				Missing:      gaps,
+			})
+		}
+		sort.Slice(out, func(i, j int) bool { return out[i].Serial < out[j].Serial })
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(out)
+}
+
+func writeParseSamples(w http.ResponseWriter, r *http.Request, report *storage.ReportDefinition, ok bool) {
+	q := r.URL.Query()
+	filter := storage.ParseSampleFilter{
+		TenantIDs:   report.TenantIDs,
+		SysObjectID: strings.Trim(strings.TrimSpace(q.Get("sys_object_id")), "."),
+		Missing:     strings.TrimSpace(q.Get("missing")),
+		Limit:       100,
+	}
+	if m := strings.TrimSpace(q.Get("manufacturer")); m != "" && !strings.EqualFold(m, reports.UnknownManufacturer) {
+		filter.Manufacturer = m
+	}
+	if v := strings.TrimSpace(q.Get("limit")); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			http.Error(w, "invalid limit", http.StatusBadRequest)
+			return
+		}
+		filter.Limit = n
+	}
+
+	out := []*storage.ParseSample{}
+	if ok {
+		samples, err := serverStore.ListParseSamples(r.Context(), filter)
+		if err != nil {
+			logError("Failed to list parse samples", "error", err)
+			http.Error(w, "failed to list samples", http.StatusInternalServerError)
+			return
+		}
+		for _, s := range samples {
+			if strings.EqualFold(q.Get("manufacturer"), reports.UnknownManufacturer) && s.Manufacturer != "" {
+				continue
+			}
+			out = append(out, s)
+		}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(out)
+}
+
+func writeParseSample(w http.ResponseWriter, r *http.Request, id int64, report *storage.ReportDefinition, ok bool) {
+	sample, err := serverStore.GetParseSample(r.Context(), id)
+	if err != nil {
+		logError("Failed to load parse sample", "id", id, "error", err)
+		http.Error(w, "failed to load sample", http.StatusInternalServerError)
+		return
+	}
+	if sample == nil || !ok || !sampleInScope(sample, report) {
+		http.Error(w, "sample not found", http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(sample)
+}
+
+func sampleInScope(sample *storage.ParseSample, report *storage.ReportDefinition) bool {
+	if len(report.TenantIDs) == 0 {
+		return true
+	}
+	return slices.Contains(report.TenantIDs, sample.TenantID)
+}
+
+// pruneParseSamples drops samples older than the retention period; devices
+// that still have gaps upload a fresh one daily.
+func pruneParseSamples(ctx context.Context) {
+	removed, err := serverStore.DeleteParseSamplesBefore(ctx, time.Now().Add(-parseSampleRetention))
+	if err != nil {
+		logWarn("Failed to prune parse samples", "error", err)
+		return
+	}
+	if removed > 0 {
+		logInfo("Pruned old parse samples", "count", removed, "retention", fmt.Sprint(parseSampleRetention))
+	}
+}
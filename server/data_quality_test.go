@@ -0,0 +1,128 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"printmaster/server/storage"
+)
+
+func TestDataQualityParseSamplesAndTenantScope(t *testing.T) {
+	store := SetupTestStore(t)
+	ctx := context.Background()
+	agents := []*storage.Agent{
+		{AgentID: "agent-a", Hostname: "a", Token: "ta", TenantID: "tenant-a", Status: "active", RegisteredAt: time.Now(), LastSeen: time.Now()},
+		{AgentID: "agent-b", Hostname: "b", Token: "tb", TenantID: "tenant-b", Status: "active", RegisteredAt: time.Now(), LastSeen: time.Now()},
+	}
+	for _, a := range agents {
+		if err := store.RegisterAgent(ctx, a); err != nil {
+			t.Fatalf("RegisterAgent: %v", err)
+		}
+		dev := &storage.Device{}
+		dev.Serial, dev.IP, dev.Manufacturer, dev.AgentID, dev.LastSeen = "10.0.0.1-"+a.AgentID, "10.0.0.1", "HP", a.AgentID, time.Now()
+		if err := store.UpsertDevice(ctx, dev); err != nil {
+			t.Fatalf("UpsertDevice: %v", err)
+		}
+	}
+
+	upload := func(agent *storage.Agent, pdus int) *httptest.ResponseRecorder {
+		raw := make([]map[string]string, pdus)
+		for i := range raw {
+			raw[i] = map[string]string{"oid": "1.3.6.1.2.1.1." + strconv.Itoa(i)}
+		}
+		body, _ := json.Marshal(map[string]interface{}{
+			"agent_id": agent.AgentID,
+			"samples": []map[string]interface{}{{
+				"ip": "10.0.0.1", "manufacturer": "HP", "sys_object_id": ".1.3.6.1.4.1.11",
+				"missing": []string{"serial", "model"}, "raw_pdus": raw, "captured_at": time.Now().UTC().Format(time.RFC3339),
+			}},
+		})
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/parse-samples/batch", bytes.NewReader(body))
+		req = req.WithContext(context.WithValue(req.Context(), agentContextKey, agent))
+		rr := httptest.NewRecorder()
+		handleParseSamplesBatch(rr, req)
+		return rr
+	}
+	if rr := upload(agents[0], 3); rr.Code != http.StatusOK {
+		t.Fatalf("upload: %d %s", rr.Code, rr.Body.String())
+	}
+	// A second upload for the same device replaces the first; oversized
+	// walks are capped
+	if rr := upload(agents[0], storage.MaxParseSamplePDUs+10); rr.Code != http.StatusOK {
+		t.Fatalf("re-upload: %d %s", rr.Code, rr.Body.String())
+	}
+	if rr := upload(agents[1], 1); rr.Code != http.StatusOK {
+		t.Fatalf("upload b: %d %s", rr.Code, rr.Body.String())
+	}
+
+	call := func(user *storage.User, path string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		rr := httptest.NewRecorder()
+		handleDataQuality(rr, InjectTestUser(req, user))
+		return rr
+	}
+	admin := NewTestUser(storage.RoleAdmin)
+	viewer := NewTestUser(storage.RoleViewer, "tenant-a")
+
+	var summary struct {
+		Summary map[string]float64       `json:"summary"`
+		Groups  []map[string]interface{} `json:"groups"`
+	}
+	rr := call(admin, "/api/v1/data-quality")
+	if err := json.Unmarshal(rr.Body.Bytes(), &summary); err != nil || rr.Code != http.StatusOK {
+		t.Fatalf("admin summary: %d %s", rr.Code, rr.Body.String())
+	}
+	if summary.Summary["affected_devices"] != 2 || summary.Summary["samples"] != 2 {
+		t.Fatalf("unexpected admin summary: %+v", summary.Summary)
+	}
+
+	rr = call(viewer, "/api/v1/data-quality")
+	if err := json.Unmarshal(rr.Body.Bytes(), &summary); err != nil || rr.Code != http.StatusOK {
+		t.Fatalf("viewer summary: %d %s", rr.Code, rr.Body.String())
+	}
+	if summary.Summary["affected_devices"] != 1 || summary.Summary["samples"] != 1 {
+		t.Fatalf("unexpected viewer summary: %+v", summary.Summary)
+	}
+
+	var devices []dataQualityDevice
+	rr = call(viewer, "/api/v1/data-quality/devices?manufacturer=hp&missing=model")
+	if err := json.Unmarshal(rr.Body.Bytes(), &devices); err != nil || len(devices) != 1 || devices[0].AgentID != "agent-a" {
+		t.Fatalf("viewer devices: %d %s", rr.Code, rr.Body.String())
+	}
+
+	var samples []*storage.ParseSample
+	rr = call(admin, "/api/v1/data-quality/samples?manufacturer=HP&missing=model")
+	if err := json.Unmarshal(rr.Body.Bytes(), &samples); err != nil || len(samples) != 2 {
+		t.Fatalf("admin samples: %d %s", rr.Code, rr.Body.String())
+	}
+	var sampleA, sampleB *storage.ParseSample
+	for _, s := range samples {
+		if s.AgentID == "agent-a" {
+			sampleA = s
+		} else {
+			sampleB = s
+		}
+	}
+	if sampleA == nil || sampleA.PDUCount != storage.MaxParseSamplePDUs || len(sampleA.RawPDUs) != 0 {
+		t.Fatalf("unexpected listed sample: %+v", sampleA)
+	}
+
+	rr = call(viewer, "/api/v1/data-quality/samples/"+strconv.FormatInt(sampleA.ID, 10))
+	var detail storage.ParseSample
+	if err := json.Unmarshal(rr.Body.Bytes(), &detail); err != nil || rr.Code != http.StatusOK {
+		t.Fatalf("sample detail: %d %s", rr.Code, rr.Body.String())
+	}
+	var pdus []json.RawMessage
+	if err := json.Unmarshal(detail.RawPDUs, &pdus); err != nil || len(pdus) != storage.MaxParseSamplePDUs {
+		t.Fatalf("expected %d PDUs, got %d (%v)", storage.MaxParseSamplePDUs, len(pdus), err)
+	}
+	if rr := call(viewer, "/api/v1/data-quality/samples/"+strconv.FormatInt(sampleB.ID, 10)); rr.Code != http.StatusNotFound {
+		t.Fatalf("other tenant's sample: expected 404, got %d", rr.Code)
+	}
+}
@@ -0,0 +1,351 @@
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	wscommon "printmaster/common/ws"
+	authz "printmaster/server/authz"
+	"printmaster/server/deepscan"
+	"printmaster/server/storage"
+)
+
+// deepScanOrchestrator runs scheduled deep scans across agents.
+var deepScanOrchestrator *deepscan.Orchestrator
+
+// wsAgentClient calls agent HTTP endpoints over the agent's WebSocket proxy
+// channel on behalf of the deep scan orchestrator.
+type wsAgentClient struct{}
+
+func (wsAgentClient) Connected(agentID string) bool {
+	return isAgentConnectedWS(agentID)
+}
+
+func (wsAgentClient) Do(ctx context.Context, agentID, method, path string, body []byte) (int, []byte, error) {
+	requestID := fmt.Sprintf("%s-deepscan-%d", agentID, time.Now().UnixNano())
+	respChan := make(chan wscommon.Message, 1)
+	proxyRequestsLock.Lock()
+	proxyRequests[requestID] = respChan
+	proxyRequestsLock.Unlock()
+	defer func() {
+		proxyRequestsLock.Lock()
+		delete(proxyRequests, requestID)
+		proxyRequestsLock.Unlock()
+		close(respChan)
+	}()
+
+	headers := map[string]string{
+		"Content-Type":       "application/json",
+		"X-PrintMaster-User": "deep-scan-scheduler",
+		"X-PrintMaster-Role": string(storage.RoleOperator),
+	}
+	if err := sendProxyRequest(agentID, requestID, "http://localhost:8080"+path, method, headers, base64.StdEncoding.EncodeToString(body)); err != nil {
+		return 0, nil, err
+	}
+
+	timer := time.NewTimer(60 * time.Second)
+	defer timer.Stop()
+	select {
+	case resp := <-respChan:
+		statusCode := http.StatusOK
+		if code, ok := resp.Data["status_code"].(float64); ok {
+			statusCode = int(code)
+		}
+		var respBody []byte
+		if bodyB64, ok := resp.Data["body"].(string); ok {
+			respBody, _ = base64.StdEncoding.DecodeString(bodyB64)
+		}
+		return statusCode, respBody, nil
+	case <-timer.C:
+		return 0, nil, fmt.Errorf("agent did not respond")
+	case <-ctx.Done():
+		return 0, nil, ctx.Err()
+	}
+}
+
+// handleDeepScanSchedules handles GET (list) and POST (create) on
+// /api/v1/deep-scans/schedules.
+func handleDeepScanSchedules(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	switch r.Method {
+	case http.MethodGet:
+		if !authorizeOrReject(w, r, authz.ActionAgentsRead, authz.ResourceRef{}) {
+			return
+		}
+		schedules, err := serverStore.ListDeepScanSchedules(ctx)
+		if err != nil {
+			logError("Failed to list deep scan schedules", "error", err)
+			http.Error(w, "failed to list deep scan schedules", http.StatusInternalServerError)
+			return
+		}
+		if schedules == nil {
+			schedules = []*storage.DeepScanSchedule{}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{"schedules": schedules})
+	case http.MethodPost:
+		if !authorizeOrReject(w, r, authz.ActionAgentsWrite, authz.ResourceRef{}) {
+			return
+		}
+		var schedule storage.DeepScanSchedule
+		if err := decodeJSONBody(r, &schedule); err != nil {
+			http.Error(w, "invalid JSON", http.StatusBadRequest)
+			return
+		}
+		schedule.Normalize()
+		if err := schedule.Validate(); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		_, _, actorName, _ := auditActorFromPrincipal(r)
+		schedule.CreatedBy = actorName
+		schedule.NextRunAt = deepscan.NextRun(&schedule, time.Now())
+		if err := serverStore.CreateDeepScanSchedule(ctx, &schedule); err != nil {
+			logError("Failed to create deep scan schedule", "error", err)
+			http.Error(w, "failed to create deep scan schedule", http.StatusInternalServerError)
+			return
+		}
+		auditDeepScan(r, "deep_scan.schedule.create", strconv.FormatInt(schedule.ID, 10),
+			fmt.Sprintf("Created deep scan schedule %q (%s at %s %s)", schedule.Name, schedule.Frequency, schedule.TimeOfDay, schedule.Timezone))
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(schedule)
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// handleDeepScanSchedule handles GET, PUT and DELETE on
+// /api/v1/deep-scans/schedules/{id} and POST on .../{id}/run.
+func handleDeepScanSchedule(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	rest := strings.TrimPrefix(r.URL.Path, "/api/v1/deep-scans/schedules/")
+	idPart, action, _ := strings.Cut(rest, "/")
+	id, err := strconv.ParseInt(idPart, 10, 64)
+	if err != nil {
+		http.Error(w, "invalid schedule id", http.StatusBadRequest)
+		return
+	}
+
+	if action == "run" {
+		if r.Method != http.MethodPost {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		if !authorizeOrReject(w, r, authz.ActionAgentsWrite, authz.ResourceRef{}) {
+			return
+		}
+		schedule, ok := loadDeepScanSchedule(w, r, id)
+		if !ok {
+			return
+		}
+		startDeepScan(w, r, schedule)
+		return
+	}
+	if action != "" {
+		http.NotFound(w, r)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		if !authorizeOrReject(w, r, authz.ActionAgentsRead, authz.ResourceRef{}) {
+			return
+		}
+		schedule, ok := loadDeepScanSchedule(w, r, id)
+		if !ok {
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(schedule)
+	case http.MethodPut:
+		if !authorizeOrReject(w, r, authz.ActionAgentsWrite, authz.ResourceRef{}) {
+			return
+		}
+		existing, ok := loadDeepScanSchedule(w, r, id)
+		if !ok {
+			return
+		}
+		var schedule storage.DeepScanSchedule
+		if err := decodeJSONBody(r, &schedule); err != nil {
+			http.Error(w, "invalid JSON", http.StatusBadRequest)
+			return
+		}
+		schedule.Normalize()
+		if err := schedule.Validate(); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		schedule.ID = existing.ID
+		schedule.CreatedBy = existing.CreatedBy
+		schedule.CreatedAt = existing.CreatedAt
+		schedule.LastRunAt = existing.LastRunAt
+		schedule.LastRunID = existing.LastRunID
+		schedule.NextRunAt = deepscan.NextRun(&schedule, time.Now())
+		if err := serverStore.UpdateDeepScanSchedule(ctx, &schedule); err != nil {
+			logError("Failed to update deep scan schedule", "id", id, "error", err)
+			http.Error(w, "failed to update deep scan schedule", http.StatusInternalServerError)
+			return
+		}
+		auditDeepScan(r, "deep_scan.schedule.update", idPart, fmt.Sprintf("Updated deep scan schedule %q", schedule.Name))
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(schedule)
+	case http.MethodDelete:
+		if !authorizeOrReject(w, r, authz.ActionAgentsWrite, authz.ResourceRef{}) {
+			return
+		}
+		existing, ok := loadDeepScanSchedule(w, r, id)
+		if !ok {
+			return
+		}
+		if err := serverStore.DeleteDeepScanSchedule(ctx, id); err != nil {
+			logError("Failed to delete deep scan schedule", "id", id, "error", err)
+			http.Error(w, "failed to delete deep scan schedule", http.StatusInternalServerError)
+			return
+		}
+		auditDeepScan(r, "deep_scan.schedule.delete", idPart, fmt.Sprintf("Deleted deep scan schedule %q", existing.Name))
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// handleDeepScanRunNow handles POST /api/v1/deep-scans/run: an ad-hoc deep
+// scan with schedule-style targeting that is not stored as a schedule.
+func handleDeepScanRunNow(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !authorizeOrReject(w, r, authz.ActionAgentsWrite, authz.ResourceRef{}) {
+		return
+	}
+	var schedule storage.DeepScanSchedule
+	if err := decodeJSONBody(r, &schedule); err != nil {
+		http.Error(w, "invalid JSON", http.StatusBadRequest)
+		return
+	}
+	schedule.ID = 0
+	if strings.TrimSpace(schedule.Name) == "" {
+		schedule.Name = "Ad-hoc deep scan"
+	}
+	schedule.Normalize()
+	if err := schedule.Validate(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	startDeepScan(w, r, &schedule)
+}
+
+func startDeepScan(w http.ResponseWriter, r *http.Request, schedule *storage.DeepScanSchedule) {
+	if deepScanOrchestrator == nil {
+		http.Error(w, "deep scan orchestrator not running", http.StatusServiceUnavailable)
+		return
+	}
+	_, _, actorName, _ := auditActorFromPrincipal(r)
+	run, err := deepScanOrchestrator.RunNow(r.Context(), schedule, actorName)
+	if err != nil {
+		logError("Failed to start deep scan", "schedule", schedule.ID, "error", err)
+		http.Error(w, "failed to start deep scan", http.StatusInternalServerError)
+		return
+	}
+	auditDeepScan(r, "deep_scan.run", strconv.FormatInt(run.ID, 10),
+		fmt.Sprintf("Started deep scan %q across %d agents", schedule.Name, run.AgentsTotal))
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(run)
+}
+
+// handleDeepScanRuns handles GET /api/v1/deep-scans/runs (newest first,
+// optional schedule_id and limit).
+func handleDeepScanRuns(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !authorizeOrReject(w, r, authz.ActionAgentsRead, authz.ResourceRef{}) {
+		return
+	}
+	scheduleID, _ := strconv.ParseInt(r.URL.Query().Get("schedule_id"), 10, 64)
+	limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+	if limit > 500 {
+		limit = 500
+	}
+	runs, err := serverStore.ListDeepScanRuns(r.Context(), scheduleID, limit)
+	if err != nil {
+		logError("Failed to list deep scan runs", "error", err)
+		http.Error(w, "failed to list deep scan runs", http.StatusInternalServerError)
+		return
+	}
+	if runs == nil {
+		runs = []*storage.DeepScanRun{}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"runs": runs})
+}
+
+// handleDeepScanRun handles GET /api/v1/deep-scans/runs/{id}: the run with
+// per-agent progress, captures and diffs.
+func handleDeepScanRun(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !authorizeOrReject(w, r, authz.ActionAgentsRead, authz.ResourceRef{}) {
+		return
+	}
+	id, err := strconv.ParseInt(strings.TrimPrefix(r.URL.Path, "/api/v1/deep-scans/runs/"), 10, 64)
+	if err != nil {
+		http.Error(w, "invalid run id", http.StatusBadRequest)
+		return
+	}
+	run, err := serverStore.GetDeepScanRun(r.Context(), id)
+	if err != nil {
+		logError("Failed to get deep scan run", "id", id, "error", err)
+		http.Error(w, "failed to get deep scan run", http.StatusInternalServerError)
+		return
+	}
+	if run == nil {
+		http.Error(w, "deep scan run not found", http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(run)
+}
+
+func loadDeepScanSchedule(w http.ResponseWriter, r *http.Request, id int64) (*storage.DeepScanSchedule, bool) {
+	schedule, err := serverStore.GetDeepScanSchedule(r.Context(), id)
+	if err != nil {
+		logError("Failed to get deep scan schedule", "id", id, "error", err)
+		http.Error(w, "failed to get deep scan schedule", http.StatusInternalServerError)
+		return nil, false
+	}
+	if schedule == nil {
+		http.Error(w, "deep scan schedule not found", http.StatusNotFound)
+		return nil, false
+	}
+	return schedule, true
+}
+
+func auditDeepScan(r *http.Request, action, targetID, details string) {
+	actorType, actorID, actorName, actorTenant := auditActorFromPrincipal(r)
+	logInfo("Deep scan change", "action", action, "target", targetID, "actor", actorName)
+	logAuditEntry(r.Context(), &storage.AuditEntry{
+		ActorType:  actorType,
+		ActorID:    actorID,
+		ActorName:  actorName,
+		TenantID:   actorTenant,
+		Action:     action,
+		TargetType: "deep_scan",
+		TargetID:   targetID,
+		Details:    details,
+		IPAddress:  extractClientIP(r),
+		UserAgent:  r.Header.Get("User-Agent"),
+	})
+}
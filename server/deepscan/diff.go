@@ -0,0 +1,80 @@
+package deepscan
+
+import (
+	"reflect"
+	"sort"
+
+	"printmaster/server/storage"
+)
+
+// deviceKey identifies a device across scans: its serial when known,
+// otherwise its IP.
+func deviceKey(r storage.DeepScanDeviceResult) string {
+	if r.Serial != "" {
+		return "serial:" + r.Serial
+	}
+	return "ip:" + r.IP
+}
+
+// Diff compares an agent's current deep scan captures with its previous
+// ones. Devices that failed to scan this time are not reported as removed,
+// since their absence says nothing about the device.
+func Diff(prev, curr []storage.DeepScanDeviceResult) []storage.DeepScanDiff {
+	before := make(map[string]storage.DeepScanDeviceResult)
+	for _, r := range prev {
+		if r.Error == "" {
+			before[deviceKey(r)] = r
+		}
+	}
+	failedIPs := make(map[string]bool)
+	seen := make(map[string]bool)
+
+	var diffs []storage.DeepScanDiff
+	for _, r := range curr {
+		if r.Error != "" {
+			failedIPs[r.IP] = true
+			continue
+		}
+		key := deviceKey(r)
+		seen[key] = true
+		old, ok := before[key]
+		if !ok {
+			diffs = append(diffs, storage.DeepScanDiff{Serial: r.Serial, IP: r.IP, Change: storage.DeepScanChangeAdded})
+			continue
+		}
+		if changes := diffFields(old.Fields, r.Fields); len(changes) > 0 {
+			diffs = append(diffs, storage.DeepScanDiff{Serial: r.Serial, IP: r.IP, Change: storage.DeepScanChangeChanged, Fields: changes})
+		}
+	}
+	for key, r := range before {
+		if seen[key] || failedIPs[r.IP] {
+			continue
+		}
+		diffs = append(diffs, storage.DeepScanDiff{Serial: r.Serial, IP: r.IP, Change: storage.DeepScanChangeRemoved})
+	}
+
+	sort.Slice(diffs, func(i, j int) bool {
+		if diffs[i].IP != diffs[j].IP {
+			return diffs[i].IP < diffs[j].IP
+		}
+		return diffs[i].Serial < diffs[j].Serial
+	})
+	return diffs
+}
+
+// diffFields returns the fields whose values differ, sorted by name.
+func diffFields(before, after map[string]interface{}) []storage.DeepScanFieldChange {
+	var changes []storage.DeepScanFieldChange
+	for field, old := range before {
+		if v, ok := after[field]; !ok || !reflect.DeepEqual(old, v) {
+			changes = append(changes, storage.DeepScanFieldChange{Field: field, Before: old, After: after[field]})
+		}
+	}
+	for field, v := range after {
+		if _, ok := before[field]; !ok {
+			changes = append(changes, storage.DeepScanFieldChange{Field: field, After: v})
+		}
+	}
+	sort.Slice(changes, func(i, j int) bool { return changes[i].Field < changes[j].Field })
+	return changes
+}
@@ -0,0 +1,425 @@
+// Package deepscan orchestrates server-initiated deep scans (full SNMP walk
+// and parse capture) across agents, aggregating results and diffs centrally.
+package deepscan
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"printmaster/server/storage"
+)
+
+// Store defines the storage operations needed by the orchestrator.
+type Store interface {
+	ListAgents(ctx context.Context) ([]*storage.Agent, error)
+	GetDueDeepScanSchedules(ctx context.Context, before time.Time) ([]*storage.DeepScanSchedule, error)
+	UpdateDeepScanScheduleAfterRun(ctx context.Context, scheduleID, runID int64, nextRun time.Time) error
+	CreateDeepScanRun(ctx context.Context, run *storage.DeepScanRun) error
+	UpdateDeepScanRun(ctx context.Context, run *storage.DeepScanRun) error
+	SaveDeepScanAgentResult(ctx context.Context, result *storage.DeepScanAgentResult) error
+	GetPreviousDeepScanAgentResult(ctx context.Context, agentID string, runID int64) (*storage.DeepScanAgentResult, error)
+}
+
+// AgentClient calls an agent's local HTTP API, typically over its WebSocket
+// connection.
+type AgentClient interface {
+	Connected(agentID string) bool
+	Do(ctx context.Context, agentID, method, path string, body []byte) (int, []byte, error)
+}
+
+// Config configures the orchestrator.
+type Config struct {
+	// Interval between checks for due schedules
+	Interval time.Duration
+	// PollInterval between agent job progress checks
+	PollInterval time.Duration
+	// JobTimeout bounds how long one agent's scan may run
+	JobTimeout time.Duration
+	// MaxConcurrentAgents limits how many agents scan at once
+	MaxConcurrentAgents int
+	// Logger for orchestration events
+	Logger *slog.Logger
+}
+
+// agentJob mirrors the agent's GET /api/deep-scan response.
+type agentJob struct {
+	ID      string                         `json:"id"`
+	Status  string                         `json:"status"`
+	Total   int                            `json:"total"`
+	Done    int                            `json:"done"`
+	Failed  int                            `json:"failed"`
+	Results []storage.DeepScanDeviceResult `json:"results"`
+}
+
+// Orchestrator runs due deep scan schedules and tracks their progress.
+type Orchestrator struct {
+	store  Store
+	client AgentClient
+	config Config
+	logger *slog.Logger
+
+	mu       sync.Mutex
+	running  bool
+	stopChan chan struct{}
+	wg       sync.WaitGroup
+}
+
+// NewOrchestrator creates a deep scan orchestrator.
+func NewOrchestrator(store Store, client AgentClient, config Config) *Orchestrator {
+	logger := config.Logger
+	if logger == nil {
+		logger = slog.Default()
+	}
+	if config.Interval == 0 {
+		config.Interval = time.Minute
+	}
+	if config.PollInterval == 0 {
+		config.PollInterval = 10 * time.Second
+	}
+	if config.JobTimeout == 0 {
+		config.JobTimeout = 2 * time.Hour
+	}
+	if config.MaxConcurrentAgents <= 0 {
+		config.MaxConcurrentAgents = 8
+	}
+	return &Orchestrator{
+		store:    store,
+		client:   client,
+		config:   config,
+		logger:   logger,
+		stopChan: make(chan struct{}),
+	}
+}
+
+// Start begins checking for due schedules.
+func (o *Orchestrator) Start() {
+	o.mu.Lock()
+	if o.running {
+		o.mu.Unlock()
+		return
+	}
+	o.running = true
+	o.stopChan = make(chan struct{})
+	o.mu.Unlock()
+
+	go o.runLoop()
+	o.logger.Info("deep scan orchestrator started", "interval", o.config.Interval)
+}
+
+// Stop halts the orchestrator and waits for in-flight runs to finish
+// recording their results.
+func (o *Orchestrator) Stop() {
+	o.mu.Lock()
+	if !o.running {
+		o.mu.Unlock()
+		return
+	}
+	o.running = false
+	close(o.stopChan)
+	o.mu.Unlock()
+	o.wg.Wait()
+	o.logger.Info("deep scan orchestrator stopped")
+}
+
+func (o *Orchestrator) runLoop() {
+	ticker := time.NewTicker(o.config.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-o.stopChan:
+			return
+		case <-ticker.C:
+			o.runDue()
+		}
+	}
+}
+
+func (o *Orchestrator) runDue() {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	now := time.Now().UTC()
+	schedules, err := o.store.GetDueDeepScanSchedules(ctx, now)
+	if err != nil {
+		o.logger.Error("failed to get due deep scan schedules", "error", err)
+		return
+	}
+	for _, sched := range schedules {
+		if _, err := o.RunNow(ctx, sched, "scheduler"); err != nil {
+			o.logger.Error("failed to start scheduled deep scan", "schedule", sched.ID, "error", err)
+		}
+	}
+}
+
+// RunNow starts a deep scan for schedule in the background and returns the
+// new run. For stored schedules the next run time is advanced.
+func (o *Orchestrator) RunNow(ctx context.Context, schedule *storage.DeepScanSchedule, triggeredBy string) (*storage.DeepScanRun, error) {
+	agentIDs, err := o.targetAgents(ctx, schedule)
+	if err != nil {
+		return nil, err
+	}
+
+	run := &storage.DeepScanRun{
+		Name:        schedule.Name,
+		Status:      storage.DeepScanStatusRunning,
+		TriggeredBy: triggeredBy,
+		StartedAt:   time.Now().UTC(),
+		AgentsTotal: len(agentIDs),
+	}
+	if schedule.ID != 0 {
+		id := schedule.ID
+		run.ScheduleID = &id
+	}
+	if err := o.store.CreateDeepScanRun(ctx, run); err != nil {
+		return nil, err
+	}
+	if schedule.ID != 0 {
+		next := NextRun(schedule, time.Now())
+		if err := o.store.UpdateDeepScanScheduleAfterRun(ctx, schedule.ID, run.ID, next); err != nil {
+			o.logger.Error("failed to advance deep scan schedule", "schedule", schedule.ID, "error", err)
+		}
+	}
+
+	results := make([]*storage.DeepScanAgentResult, 0, len(agentIDs))
+	for _, agentID := range agentIDs {
+		result := &storage.DeepScanAgentResult{RunID: run.ID, AgentID: agentID, Status: storage.DeepScanStatusPending}
+		if err := o.store.SaveDeepScanAgentResult(ctx, result); err != nil {
+			return nil, err
+		}
+		results = append(results, result)
+	}
+
+	o.logger.Info("deep scan started", "run", run.ID, "schedule", schedule.ID, "agents", len(agentIDs), "triggered_by", triggeredBy)
+	snapshot := *run
+	o.wg.Add(1)
+	go func() {
+		defer o.wg.Done()
+		o.execute(run, schedule, results)
+	}()
+	return &snapshot, nil
+}
+
+// targetAgents returns the schedule's agents, or every registered agent
+// when none are listed.
+func (o *Orchestrator) targetAgents(ctx context.Context, schedule *storage.DeepScanSchedule) ([]string, error) {
+	if len(schedule.AgentIDs) > 0 {
+		return append([]string(nil), schedule.AgentIDs...), nil
+	}
+	agents, err := o.store.ListAgents(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("list agents: %w", err)
+	}
+	ids := make([]string, 0, len(agents))
+	for _, a := range agents {
+		ids = append(ids, a.AgentID)
+	}
+	return ids, nil
+}
+
+// execute runs each agent's scan, records progress and aggregates the run.
+func (o *Orchestrator) execute(run *storage.DeepScanRun, schedule *storage.DeepScanSchedule, results []*storage.DeepScanAgentResult) {
+	var mu sync.Mutex
+	sem := make(chan struct{}, o.config.MaxConcurrentAgents)
+	var wg sync.WaitGroup
+	for _, result := range results {
+		wg.Add(1)
+		go func(result *storage.DeepScanAgentResult) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+
+			o.scanAgent(run.ID, schedule, result)
+
+			mu.Lock()
+			defer mu.Unlock()
+			aggregate(run, result)
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			defer cancel()
+			if err := o.store.UpdateDeepScanRun(ctx, run); err != nil {
+				o.logger.Error("failed to update deep scan run", "run", run.ID, "error", err)
+			}
+		}(result)
+	}
+	wg.Wait()
+
+	completed := time.Now().UTC()
+	run.CompletedAt = &completed
+	run.Status = runStatus(run)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := o.store.UpdateDeepScanRun(ctx, run); err != nil {
+		o.logger.Error("failed to update deep scan run", "run", run.ID, "error", err)
+	}
+	o.logger.Info("deep scan finished", "run", run.ID, "status", run.Status,
+		"agents_failed", run.AgentsFailed, "devices_scanned", run.DevicesScanned,
+		"devices_failed", run.DevicesFailed, "devices_changed", run.DevicesChanged)
+}
+
+// aggregate adds a finished agent result to the run counters.
+func aggregate(run *storage.DeepScanRun, result *storage.DeepScanAgentResult) {
+	switch result.Status {
+	case storage.DeepScanStatusCompleted, storage.DeepScanStatusPartial:
+		run.AgentsCompleted++
+	default:
+		run.AgentsFailed++
+	}
+	run.DevicesScanned += result.Done - result.Failed
+	run.DevicesFailed += result.Failed
+	run.DevicesChanged += len(result.Diffs)
+}
+
+// runStatus derives the overall status once every agent has finished.
+func runStatus(run *storage.DeepScanRun) string {
+	switch {
+	case run.AgentsTotal > 0 && run.AgentsCompleted == 0:
+		return storage.DeepScanStatusFailed
+	case run.AgentsFailed > 0 || run.DevicesFailed > 0:
+		return storage.DeepScanStatusPartial
+	default:
+		return storage.DeepScanStatusCompleted
+	}
+}
+
+// scanAgent starts the agent's deep scan job, polls it until it finishes
+// and stores its results and diffs. Failures are recorded on result.
+func (o *Orchestrator) scanAgent(runID int64, schedule *storage.DeepScanSchedule, result *storage.DeepScanAgentResult) {
+	ctx, cancel := context.WithTimeout(context.Background(), o.config.JobTimeout)
+	defer cancel()
+
+	started := time.Now().UTC()
+	result.StartedAt = &started
+	fail := func(status, msg string) {
+		result.Status = status
+		result.Error = msg
+		o.finish(result)
+		o.logger.Warn("deep scan agent failed", "run", runID, "agent", result.AgentID, "status", status, "error", msg)
+	}
+
+	if !o.client.Connected(result.AgentID) {
+		fail(storage.DeepScanStatusOffline, "agent not connected")
+		return
+	}
+
+	body, _ := json.Marshal(map[string]interface{}{
+		"ranges":        schedule.Ranges,
+		"include_saved": schedule.IncludeSaved,
+	})
+	status, resp, err := o.client.Do(ctx, result.AgentID, http.MethodPost, "/api/deep-scan", body)
+	if err != nil {
+		fail(storage.DeepScanStatusFailed, fmt.Sprintf("start scan: %v", err))
+		return
+	}
+	if status != http.StatusAccepted && status != http.StatusOK {
+		fail(storage.DeepScanStatusFailed, fmt.Sprintf("start scan: agent returned %d", status))
+		return
+	}
+	var accepted struct {
+		JobID string `json:"job_id"`
+		Total int    `json:"total"`
+	}
+	if err := json.Unmarshal(resp, &accepted); err != nil || accepted.JobID == "" {
+		fail(storage.DeepScanStatusFailed, "start scan: invalid agent response")
+		return
+	}
+	result.JobID = accepted.JobID
+	result.Total = accepted.Total
+	result.Status = storage.DeepScanStatusRunning
+	o.save(result)
+
+	var job agentJob
+	path := "/api/deep-scan?id=" + url.QueryEscape(result.JobID)
+	for {
+		select {
+		case <-ctx.Done():
+			fail(storage.DeepScanStatusFailed, fmt.Sprintf("timed out after %s (%d/%d devices)", o.config.JobTimeout, result.Done, result.Total))
+			return
+		case <-o.stopChan:
+			fail(storage.DeepScanStatusFailed, "server shutting down")
+			return
+		case <-time.After(o.config.PollInterval):
+		}
+
+		status, resp, err := o.client.Do(ctx, result.AgentID, http.MethodGet, path, nil)
+		if err != nil {
+			if !o.client.Connected(result.AgentID) {
+				fail(storage.DeepScanStatusOffline, "agent disconnected during scan")
+				return
+			}
+			continue
+		}
+		if status == http.StatusNotFound {
+			fail(storage.DeepScanStatusFailed, "scan job no longer available on agent")
+			return
+		}
+		if status != http.StatusOK || json.Unmarshal(resp, &job) != nil {
+			continue
+		}
+		result.Total, result.Done, result.Failed = job.Total, job.Done, job.Failed
+		if job.Status == storage.DeepScanStatusCompleted {
+			break
+		}
+		o.save(result)
+	}
+
+	result.Results = job.Results
+	prev, err := o.store.GetPreviousDeepScanAgentResult(ctx, result.AgentID, runID)
+	if err != nil {
+		o.logger.Error("failed to load previous deep scan", "agent", result.AgentID, "error", err)
+	} else if prev != nil {
+		result.Diffs = Diff(prev.Results, result.Results)
+	}
+	result.Status = storage.DeepScanStatusCompleted
+	if result.Failed > 0 {
+		result.Status = storage.DeepScanStatusPartial
+		result.Error = fmt.Sprintf("%d of %d devices failed", result.Failed, result.Total)
+	}
+	o.finish(result)
+}
+
+func (o *Orchestrator) finish(result *storage.DeepScanAgentResult) {
+	completed := time.Now().UTC()
+	result.CompletedAt = &completed
+	o.save(result)
+}
+
+func (o *Orchestrator) save(result *storage.DeepScanAgentResult) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := o.store.SaveDeepScanAgentResult(ctx, result); err != nil {
+		o.logger.Error("failed to save deep scan agent result", "run", result.RunID, "agent", result.AgentID, "error", err)
+	}
+}
+
+// NextRun returns the schedule's next run time after now.
+func NextRun(schedule *storage.DeepScanSchedule, now time.Time) time.Time {
+	var hour, min int
+	fmt.Sscanf(schedule.TimeOfDay, "%d:%d", &hour, &min)
+
+	loc := time.UTC
+	if schedule.Timezone != "" {
+		if l, err := time.LoadLocation(schedule.Timezone); err == nil {
+			loc = l
+		}
+	}
+	nowLocal := now.In(loc)
+	next := time.Date(nowLocal.Year(), nowLocal.Month(), nowLocal.Day(), hour, min, 0, 0, loc)
+
+	if schedule.Frequency == storage.ScheduleFrequencyWeekly {
+		daysUntil := (schedule.DayOfWeek - int(next.Weekday()) + 7) % 7
+		if daysUntil == 0 && !next.After(now) {
+			daysUntil = 7
+		}
+		return next.AddDate(0, 0, daysUntil).UTC()
+	}
+	if !next.After(now) {
+		next = next.AddDate(0, 0, 1)
+	}
+	return next.UTC()
+}
@@ -0,0 +1,242 @@
+package deepscan
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"printmaster/server/storage"
+)
+
+type fakeStore struct {
+	mu       sync.Mutex
+	agents   []*storage.Agent
+	runs     map[int64]*storage.DeepScanRun
+	results  map[int64]*storage.DeepScanAgentResult
+	previous map[string]*storage.DeepScanAgentResult
+	advanced map[int64]time.Time
+}
+
+func newFakeStore() *fakeStore {
+	return &fakeStore{
+		runs:     make(map[int64]*storage.DeepScanRun),
+		results:  make(map[int64]*storage.DeepScanAgentResult),
+		previous: make(map[string]*storage.DeepScanAgentResult),
+		advanced: make(map[int64]time.Time),
+	}
+}
+
+func (s *fakeStore) ListAgents(ctx context.Context) ([]*storage.Agent, error) { return s.agents, nil }
+
+func (s *fakeStore) GetDueDeepScanSchedules(ctx context.Context, before time.Time) ([]*storage.DeepScanSchedule, error) {
+	return nil, nil
+}
+
+func (s *fakeStore) UpdateDeepScanScheduleAfterRun(ctx context.Context, scheduleID, runID int64, nextRun time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.advanced[scheduleID] = nextRun
+	return nil
+}
+
+func (s *fakeStore) CreateDeepScanRun(ctx context.Context, run *storage.DeepScanRun) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	run.ID = int64(len(s.runs) + 1)
+	copied := *run
+	s.runs[run.ID] = &copied
+	return nil
+}
+
+func (s *fakeStore) UpdateDeepScanRun(ctx context.Context, run *storage.DeepScanRun) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	copied := *run
+	s.runs[run.ID] = &copied
+	return nil
+}
+
+func (s *fakeStore) SaveDeepScanAgentResult(ctx context.Context, result *storage.DeepScanAgentResult) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if result.ID == 0 {
+		result.ID = int64(len(s.results) + 1)
+	}
+	copied := *result
+	s.results[result.ID] = &copied
+	return nil
+}
+
+func (s *fakeStore) GetPreviousDeepScanAgentResult(ctx context.Context, agentID string, runID int64) (*storage.DeepScanAgentResult, error) {
+	return s.previous[agentID], nil
+}
+
+func (s *fakeStore) run(id int64) storage.DeepScanRun {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return *s.runs[id]
+}
+
+func (s *fakeStore) agentResult(agentID string) storage.DeepScanAgentResult {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, r := range s.results {
+		if r.AgentID == agentID {
+			return *r
+		}
+	}
+	return storage.DeepScanAgentResult{}
+}
+
+// fakeAgents answers the agent deep-scan API for connected agents. Each job
+// completes on its second poll.
+type fakeAgents struct {
+	mu        sync.Mutex
+	connected map[string]bool
+	results   map[string][]storage.DeepScanDeviceResult
+	startErr  map[string]error
+	polls     map[string]int
+}
+
+func (f *fakeAgents) Connected(agentID string) bool { return f.connected[agentID] }
+
+func (f *fakeAgents) Do(ctx context.Context, agentID, method, path string, body []byte) (int, []byte, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if method == http.MethodPost {
+		if err := f.startErr[agentID]; err != nil {
+			return 0, nil, err
+		}
+		resp, _ := json.Marshal(map[string]interface{}{"job_id": "job-" + agentID, "total": len(f.results[agentID])})
+		return http.StatusAccepted, resp, nil
+	}
+	if !strings.HasSuffix(path, "job-"+agentID) {
+		return http.StatusNotFound, nil, nil
+	}
+	f.polls[agentID]++
+	job := agentJob{ID: "job-" + agentID, Status: "running", Total: len(f.results[agentID])}
+	if f.polls[agentID] >= 2 {
+		job.Status = "completed"
+		job.Results = f.results[agentID]
+		job.Done = len(job.Results)
+		for _, r := range job.Results {
+			if r.Error != "" {
+				job.Failed++
+			}
+		}
+	}
+	resp, _ := json.Marshal(job)
+	return http.StatusOK, resp, nil
+}
+
+func TestOrchestratorRunAggregatesAgents(t *testing.T) {
+	t.Parallel()
+
+	store := newFakeStore()
+	store.agents = []*storage.Agent{{AgentID: "a1"}, {AgentID: "a2"}, {AgentID: "a3"}, {AgentID: "a4"}}
+	store.previous["a1"] = &storage.DeepScanAgentResult{Results: []storage.DeepScanDeviceResult{
+		{IP: "10.0.0.1", Serial: "S1", Fields: map[string]interface{}{"firmware": "1.0"}},
+	}}
+	agents := &fakeAgents{
+		connected: map[string]bool{"a1": true, "a2": true, "a4": true},
+		results: map[string][]storage.DeepScanDeviceResult{
+			"a1": {{IP: "10.0.0.1", Serial: "S1", Fields: map[string]interface{}{"firmware": "1.1"}}},
+			"a2": {{IP: "10.0.1.1", Serial: "S2"}, {IP: "10.0.1.2", Error: "no SNMP response"}},
+		},
+		startErr: map[string]error{"a4": errors.New("agent did not respond")},
+		polls:    make(map[string]int),
+	}
+	o := NewOrchestrator(store, agents, Config{PollInterval: time.Millisecond, JobTimeout: 5 * time.Second})
+
+	schedule := &storage.DeepScanSchedule{ID: 7, Name: "Nightly", IncludeSaved: true, Frequency: "daily", TimeOfDay: "02:00", Timezone: "UTC"}
+	run, err := o.RunNow(context.Background(), schedule, "tester")
+	if err != nil {
+		t.Fatalf("RunNow: %v", err)
+	}
+	o.wg.Wait()
+
+	got := store.run(run.ID)
+	if got.Status != storage.DeepScanStatusPartial {
+		t.Errorf("expected partial run, got %s", got.Status)
+	}
+	if got.AgentsTotal != 4 || got.AgentsCompleted != 2 || got.AgentsFailed != 2 {
+		t.Errorf("unexpected agent counters: %+v", got)
+	}
+	if got.DevicesScanned != 2 || got.DevicesFailed != 1 || got.DevicesChanged != 1 {
+		t.Errorf("unexpected device counters: %+v", got)
+	}
+	if _, ok := store.advanced[7]; !ok {
+		t.Error("expected schedule next run to be advanced")
+	}
+
+	if r := store.agentResult("a1"); r.Status != storage.DeepScanStatusCompleted || len(r.Diffs) != 1 || r.Diffs[0].Fields[0].Field != "firmware" {
+		t.Errorf("unexpected a1 result: %+v", r)
+	}
+	if r := store.agentResult("a2"); r.Status != storage.DeepScanStatusPartial || r.Done != 2 || r.Failed != 1 {
+		t.Errorf("unexpected a2 result: %+v", r)
+	}
+	if r := store.agentResult("a3"); r.Status != storage.DeepScanStatusOffline {
+		t.Errorf("expected a3 offline, got %+v", r)
+	}
+	if r := store.agentResult("a4"); r.Status != storage.DeepScanStatusFailed || r.Error == "" {
+		t.Errorf("expected a4 failed with error, got %+v", r)
+	}
+}
+
+func TestDiff(t *testing.T) {
+	t.Parallel()
+
+	prev := []storage.DeepScanDeviceResult{
+		{IP: "10.0.0.1", Serial: "S1", Fields: map[string]interface{}{"firmware": "1.0", "page_count": 100.0}},
+		{IP: "10.0.0.2", Serial: "S2", Fields: map[string]interface{}{"firmware": "2.0"}},
+		{IP: "10.0.0.3", Serial: "S3"},
+		{IP: "10.0.0.4", Serial: "S4"},
+	}
+	curr := []storage.DeepScanDeviceResult{
+		{IP: "10.0.0.1", Serial: "S1", Fields: map[string]interface{}{"firmware": "1.1", "page_count": 100.0, "location": "Lobby"}},
+		{IP: "10.0.0.2", Serial: "S2", Fields: map[string]interface{}{"firmware": "2.0"}},
+		{IP: "10.0.0.3", Error: "timeout"},
+		{IP: "10.0.0.5", Serial: "S5"},
+	}
+
+	diffs := Diff(prev, curr)
+	if len(diffs) != 3 {
+		t.Fatalf("expected 3 diffs, got %+v", diffs)
+	}
+	if diffs[0].Serial != "S1" || diffs[0].Change != storage.DeepScanChangeChanged || len(diffs[0].Fields) != 2 {
+		t.Errorf("unexpected change diff: %+v", diffs[0])
+	}
+	if diffs[0].Fields[0].Field != "firmware" || diffs[0].Fields[1].Field != "location" {
+		t.Errorf("expected sorted field changes, got %+v", diffs[0].Fields)
+	}
+	if diffs[1].Serial != "S4" || diffs[1].Change != storage.DeepScanChangeRemoved {
+		t.Errorf("expected S4 removed, got %+v", diffs[1])
+	}
+	if diffs[2].Serial != "S5" || diffs[2].Change != storage.DeepScanChangeAdded {
+		t.Errorf("expected S5 added, got %+v", diffs[2])
+	}
+}
+
+func TestNextRun(t *testing.T) {
+	t.Parallel()
+
+	// Wednesday 2026-03-04 10:00 UTC
+	now := time.Date(2026, 3, 4, 10, 0, 0, 0, time.UTC)
+	daily := &storage.DeepScanSchedule{Frequency: "daily", TimeOfDay: "02:30", Timezone: "UTC"}
+	if got, want := NextRun(daily, now), time.Date(2026, 3, 5, 2, 30, 0, 0, time.UTC); !got.Equal(want) {
+		t.Errorf("daily next run = %v, want %v", got, want)
+	}
+	weekly := &storage.DeepScanSchedule{Frequency: "weekly", DayOfWeek: 0, TimeOfDay: "01:00", Timezone: "UTC"}
+	if got, want := NextRun(weekly, now), time.Date(2026, 3, 8, 1, 0, 0, 0, time.UTC); !got.Equal(want) {
+		t.Errorf("weekly next run = %v, want %v", got, want)
+	}
+	sameDay := &storage.DeepScanSchedule{Frequency: "weekly", DayOfWeek: 3, TimeOfDay: "09:00", Timezone: "UTC"}
+	if got, want := NextRun(sameDay, now), time.Date(2026, 3, 11, 9, 0, 0, 0, time.UTC); !got.Equal(want) {
+		t.Errorf("weekly past-time next run = %v, want %v", got, want)
+	}
+}
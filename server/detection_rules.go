@@ -0,0 +1,228 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"printmaster/common/vendorrules"
+	authz "printmaster/server/authz"
+	"printmaster/server/storage"
+)
+
+// detectionRuleRequest is the editable part of a detection rule.
+type detectionRuleRequest struct {
+	Manufacturer      string `json:"manufacturer"`
+	SysObjectIDPrefix string `json:"sys_object_id_prefix"`
+	SysDescrRegex     string `json:"sys_descr_regex"`
+	ModelRegex        string `json:"model_regex"`
+	VendorModule      string `json:"vendor_module"`
+	Priority          int    `json:"priority"`
+	Enabled           *bool  `json:"enabled"`
+	Description       string `json:"description"`
+}
+
+func (req detectionRuleRequest) apply(rule *storage.DetectionRule) {
+	rule.Manufacturer = req.Manufacturer
+	rule.SysObjectIDPrefix = req.SysObjectIDPrefix
+	rule.SysDescrRegex = req.SysDescrRegex
+	rule.ModelRegex = req.ModelRegex
+	rule.VendorModule = req.VendorModule
+	rule.Priority = req.Priority
+	rule.Description = strings.TrimSpace(req.Description)
+	if req.Enabled != nil {
+		rule.Enabled = *req.Enabled
+	}
+}
+
+// handleDetectionRules lists and creates vendor detection rules:
+//
+//	GET  /api/v1/detection-rules
+//	POST /api/v1/detection-rules
+//
+// Rules are fleet-wide; enabled rules reach every agent with its managed
+// settings.
+func handleDetectionRules(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	switch r.Method {
+	case http.MethodGet:
+		if !authorizeOrReject(w, r, authz.ActionDetectionRulesRead, authz.ResourceRef{}) {
+			return
+		}
+		rules, err := serverStore.ListDetectionRules(ctx)
+		if err != nil {
+			logError("Failed to list detection rules", "error", err)
+			http.Error(w, "failed to list detection rules", http.StatusInternalServerError)
+			return
+		}
+		if rules == nil {
+			rules = []*storage.DetectionRule{}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(rules)
+	case http.MethodPost:
+		if !authorizeOrReject(w, r, authz.ActionDetectionRulesWrite, authz.ResourceRef{}) {
+			return
+		}
+		var req detectionRuleRequest
+		if err := decodeJSONBody(r, &req); err != nil {
+			http.Error(w, "invalid JSON", http.StatusBadRequest)
+			return
+		}
+		rule := &storage.DetectionRule{Rule: vendorrules.Rule{Enabled: true}}
+		req.apply(rule)
+		if err := rule.Validate(); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		_, _, actorName, _ := auditActorFromPrincipal(r)
+		rule.CreatedBy = actorName
+		if err := serverStore.CreateDetectionRule(ctx, rule); err != nil {
+			logError("Failed to create detection rule", "error", err)
+			http.Error(w, "failed to create detection rule", http.StatusInternalServerError)
+			return
+		}
+		auditDetectionRule(r, "detection_rule.create", rule)
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(rule)
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// handleDetectionRule reads, edits, deletes and tests rules:
+//
+//	GET    /api/v1/detection-rules/{id}
+//	PUT    /api/v1/detection-rules/{id}
+//	DELETE /api/v1/detection-rules/{id}
+//	POST   /api/v1/detection-rules/test   {"sys_object_id": "...", "sys_descr": "..."}
+func handleDetectionRule(w http.ResponseWriter, r *http.Request) {
+	rest := strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/v1/detection-rules/"), "/")
+	if rest == "test" {
+		handleDetectionRuleTest(w, r)
+		return
+	}
+	id, err := strconv.ParseInt(rest, 10, 64)
+	if err != nil {
+		http.Error(w, "invalid rule id", http.StatusBadRequest)
+		return
+	}
+	ctx := r.Context()
+
+	action := authz.ActionDetectionRulesWrite
+	if r.Method == http.MethodGet {
+		action = authz.ActionDetectionRulesRead
+	}
+	if !authorizeOrReject(w, r, action, authz.ResourceRef{}) {
+		return
+	}
+	rule, err := serverStore.GetDetectionRule(ctx, id)
+	if err != nil {
+		logError("Failed to load detection rule", "id", id, "error", err)
+		http.Error(w, "failed to load detection rule", http.StatusInternalServerError)
+		return
+	}
+	if rule == nil {
+		http.Error(w, "detection rule not found", http.StatusNotFound)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(rule)
+	case http.MethodPut:
+		var req detectionRuleRequest
+		if err := decodeJSONBody(r, &req); err != nil {
+			http.Error(w, "invalid JSON", http.StatusBadRequest)
+			return
+		}
+		req.apply(rule)
+		if err := rule.Validate(); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err := serverStore.UpdateDetectionRule(ctx, rule); err != nil {
+			logError("Failed to update detection rule", "id", id, "error", err)
+			http.Error(w, "failed to update detection rule", http.StatusInternalServerError)
+			return
+		}
+		auditDetectionRule(r, "detection_rule.update", rule)
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(rule)
+	case http.MethodDelete:
+		if err := serverStore.DeleteDetectionRule(ctx, id); err != nil {
+			logError("Failed to delete detection rule", "id", id, "error", err)
+			http.Error(w, "failed to delete detection rule", http.StatusInternalServerError)
+			return
+		}
+		auditDetectionRule(r, "detection_rule.delete", rule)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// handleDetectionRuleTest reports which enabled rule, if any, matches a
+// sysObjectID/sysDescr pair, so admins can check a rule before agents use it.
+func handleDetectionRuleTest(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "POST only", http.StatusMethodNotAllowed)
+		return
+	}
+	if !authorizeOrReject(w, r, authz.ActionDetectionRulesRead, authz.ResourceRef{}) {
+		return
+	}
+	var req struct {
+		SysObjectID string `json:"sys_object_id"`
+		SysDescr    string `json:"sys_descr"`
+	}
+	if err := decodeJSONBody(r, &req); err != nil {
+		http.Error(w, "invalid JSON", http.StatusBadRequest)
+		return
+	}
+	stored, err := serverStore.ListDetectionRules(r.Context())
+	if err != nil {
+		logError("Failed to list detection rules", "error", err)
+		http.Error(w, "failed to list detection rules", http.StatusInternalServerError)
+		return
+	}
+	rules := make([]vendorrules.Rule, 0, len(stored))
+	for _, rule := range stored {
+		rules = append(rules, rule.Rule)
+	}
+	set, _ := vendorrules.Compile(rules)
+	resp := map[string]interface{}{"matched": false}
+	if m, ok := set.Match(req.SysObjectID, req.SysDescr); ok {
+		resp = map[string]interface{}{
+			"matched":       true,
+			"rule_id":       m.RuleID,
+			"manufacturer":  m.Manufacturer,
+			"model":         m.Model,
+			"vendor_module": m.VendorModule,
+		}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+func auditDetectionRule(r *http.Request, action string, rule *storage.DetectionRule) {
+	actorType, actorID, actorName, actorTenant := auditActorFromPrincipal(r)
+	logInfo("Detection rule change", "action", action, "id", rule.ID, "actor", actorName)
+	logAuditEntry(r.Context(), &storage.AuditEntry{
+		ActorType:  actorType,
+		ActorID:    actorID,
+		ActorName:  actorName,
+		TenantID:   actorTenant,
+		Action:     action,
+		TargetType: "detection_rule",
+		TargetID:   strconv.FormatInt(rule.ID, 10),
+		Details: fmt.Sprintf("manufacturer=%s prefix=%s sysdescr=%s enabled=%t",
+			rule.Manufacturer, rule.SysObjectIDPrefix, rule.SysDescrRegex, rule.Enabled),
+		IPAddress: extractClientIP(r),
+		UserAgent: r.Header.Get("User-Agent"),
+	})
+}
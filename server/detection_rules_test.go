@@ -0,0 +1,89 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"printmaster/server/storage"
+)
+
+func TestDetectionRulesCRUD(t *testing.T) {
+	SetupTestStore(t)
+	admin := NewTestUser(storage.RoleAdmin)
+	viewer := NewTestUser(storage.RoleViewer, "tenant-a")
+
+	call := func(handler http.HandlerFunc, user *storage.User, method, path string, body interface{}) *httptest.ResponseRecorder {
+		t.Helper()
+		var buf bytes.Buffer
+		if body != nil {
+			json.NewEncoder(&buf).Encode(body)
+		}
+		req := httptest.NewRequest(method, path, &buf)
+		rr := httptest.NewRecorder()
+		handler(rr, InjectTestUser(req, user))
+		return rr
+	}
+
+	rule := map[string]interface{}{
+		"manufacturer":         "Acme",
+		"sys_object_id_prefix": "1.3.6.1.4.1.55555",
+		"model_regex":          `acme (\S+)`,
+		"priority":             5,
+	}
+	if rr := call(handleDetectionRules, viewer, http.MethodPost, "/api/v1/detection-rules", rule); rr.Code != http.StatusForbidden {
+		t.Fatalf("viewer create: expected 403, got %d", rr.Code)
+	}
+	bad := map[string]interface{}{"manufacturer": "Acme", "sys_descr_regex": "(unclosed"}
+	if rr := call(handleDetectionRules, admin, http.MethodPost, "/api/v1/detection-rules", bad); rr.Code != http.StatusBadRequest {
+		t.Fatalf("bad regex: expected 400, got %d", rr.Code)
+	}
+
+	rr := call(handleDetectionRules, admin, http.MethodPost, "/api/v1/detection-rules", rule)
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("create: %d %s", rr.Code, rr.Body.String())
+	}
+	var created storage.DetectionRule
+	json.Unmarshal(rr.Body.Bytes(), &created)
+	if created.ID == 0 || !created.Enabled || created.CreatedBy == "" {
+		t.Fatalf("unexpected created rule: %+v", created)
+	}
+	path := fmt.Sprintf("/api/v1/detection-rules/%d", created.ID)
+
+	rr = call(handleDetectionRules, viewer, http.MethodGet, "/api/v1/detection-rules", nil)
+	var list []storage.DetectionRule
+	if err := json.Unmarshal(rr.Body.Bytes(), &list); err != nil || len(list) != 1 {
+		t.Fatalf("viewer list: %d %s", rr.Code, rr.Body.String())
+	}
+
+	rr = call(handleDetectionRule, admin, http.MethodPost, "/api/v1/detection-rules/test",
+		map[string]string{"sys_object_id": "1.3.6.1.4.1.55555.1.2", "sys_descr": "ACME X900 MFP"})
+	var match map[string]interface{}
+	json.Unmarshal(rr.Body.Bytes(), &match)
+	if match["matched"] != true || match["manufacturer"] != "Acme" || match["model"] != "X900" {
+		t.Fatalf("unexpected test result: %s", rr.Body.String())
+	}
+
+	rule["enabled"] = false
+	if rr := call(handleDetectionRule, admin, http.MethodPut, path, rule); rr.Code != http.StatusOK {
+		t.Fatalf("update: %d %s", rr.Code, rr.Body.String())
+	}
+	rr = call(handleDetectionRule, admin, http.MethodPost, "/api/v1/detection-rules/test",
+		map[string]string{"sys_object_id": "1.3.6.1.4.1.55555.1.2"})
+	if !bytes.Contains(rr.Body.Bytes(), []byte(`"matched":false`)) {
+		t.Fatalf("disabled rule should not match: %s", rr.Body.String())
+	}
+
+	if rr := call(handleDetectionRule, viewer, http.MethodDelete, path, nil); rr.Code != http.StatusForbidden {
+		t.Fatalf("viewer delete: expected 403, got %d", rr.Code)
+	}
+	if rr := call(handleDetectionRule, admin, http.MethodDelete, path, nil); rr.Code != http.StatusNoContent {
+		t.Fatalf("delete: %d %s", rr.Code, rr.Body.String())
+	}
+	if rr := call(handleDetectionRule, admin, http.MethodGet, path, nil); rr.Code != http.StatusNotFound {
+		t.Fatalf("get after delete: expected 404, got %d", rr.Code)
+	}
+}
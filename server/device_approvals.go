@@ -0,0 +1,314 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	authz "printmaster/server/authz"
+	"printmaster/server/storage"
+)
+
+// deviceApprovalGate decides the approval state of devices uploaded by one
+// agent batch. The tenant policy is loaded once per batch.
+type deviceApprovalGate struct {
+	store  storage.Store
+	agent  *storage.Agent
+	policy *storage.DeviceApprovalPolicy
+}
+
+func newDeviceApprovalGate(ctx context.Context, store storage.Store, agent *storage.Agent) *deviceApprovalGate {
+	gate := &deviceApprovalGate{store: store, agent: agent}
+	if store == nil || agent == nil {
+		return gate
+	}
+	policy, err := store.GetDeviceApprovalPolicy(ctx, agent.TenantID)
+	if err != nil {
+		logWarn("Failed to load device approval policy", "tenant_id", agent.TenantID, "error", err)
+		return gate
+	}
+	gate.policy = policy
+	return gate
+}
+
+// Record creates an approval record for a device the server has not seen
+// before. Must be called before the device is upserted. Returns the status
+// assigned, or "" when the device needs no approval.
+func (g *deviceApprovalGate) Record(ctx context.Context, device *storage.Device) string {
+	if g == nil || g.policy == nil || !g.policy.Enabled || device == nil {
+		return ""
+	}
+	if existing, _ := g.store.GetDevice(ctx, device.Serial); existing != nil {
+		return ""
+	}
+
+	approval := &storage.DeviceApproval{
+		Serial:       device.Serial,
+		TenantID:     g.agent.TenantID,
+		AgentID:      g.agent.AgentID,
+		Status:       storage.DeviceApprovalPending,
+		Manufacturer: device.Manufacturer,
+		Model:        device.Model,
+		IP:           device.IP,
+		Reason:       "awaiting operator review",
+	}
+	if ok, rule := g.policy.AutoApproves(device.Manufacturer, device.Model); ok {
+		approval.Status = storage.DeviceApprovalApproved
+		approval.Reason = "auto-approved: " + rule
+	}
+	if err := g.store.CreateDeviceApproval(ctx, approval); err != nil {
+		logWarn("Failed to record device approval", "serial", device.Serial, "error", err)
+		return ""
+	}
+	logInfo("New device recorded for approval", "serial", device.Serial, "agent_id", g.agent.AgentID, "status", approval.Status)
+	return approval.Status
+}
+
+// filterApprovedDevices drops devices that are pending approval or rejected.
+// On lookup failure devices are returned unfiltered so reports keep working.
+func filterApprovedDevices(ctx context.Context, store storage.Store, devices []*storage.Device) []*storage.Device {
+	unapproved, err := store.ListUnapprovedDeviceSerials(ctx)
+	if err != nil {
+		logWarn("Failed to load unapproved devices", "error", err)
+		return devices
+	}
+	if len(unapproved) == 0 {
+		return devices
+	}
+	filtered := make([]*storage.Device, 0, len(devices))
+	for _, d := range devices {
+		if _, skip := unapproved[d.Serial]; skip {
+			continue
+		}
+		filtered = append(filtered, d)
+	}
+	return filtered
+}
+
+// approvedDeviceStore wraps storage.Store so consumers such as the alert
+// evaluator only see approved devices.
+type approvedDeviceStore struct {
+	storage.Store
+}
+
+func (s approvedDeviceStore) ListAllDevices(ctx context.Context) ([]*storage.Device, error) {
+	devices, err := s.Store.ListAllDevices(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return filterApprovedDevices(ctx, s.Store, devices), nil
+}
+
+// handleDeviceApprovals lists device approval records (GET ?status=&tenant_id=).
+func handleDeviceApprovals(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "GET only", http.StatusMethodNotAllowed)
+		return
+	}
+	tenantID := strings.TrimSpace(r.URL.Query().Get("tenant_id"))
+	var resource authz.ResourceRef
+	if tenantID != "" {
+		resource.TenantIDs = []string{tenantID}
+	}
+	if !authorizeOrReject(w, r, authz.ActionDevicesRead, resource) {
+		return
+	}
+	scope, ok := tenantScope(getPrincipal(r))
+	if !ok {
+		http.Error(w, "forbidden", http.StatusForbidden)
+		return
+	}
+
+	filter := storage.DeviceApprovalFilter{Status: strings.TrimSpace(r.URL.Query().Get("status"))}
+	if tenantID != "" {
+		filter.TenantIDs = []string{tenantID}
+	} else if scope != nil {
+		for id := range scope {
+			filter.TenantIDs = append(filter.TenantIDs, id)
+		}
+	}
+
+	approvals, err := serverStore.ListDeviceApprovals(r.Context(), filter)
+	if err != nil {
+		logError("Failed to list device approvals", "error", err)
+		http.Error(w, "failed to list device approvals", http.StatusInternalServerError)
+		return
+	}
+	if approvals == nil {
+		approvals = []*storage.DeviceApproval{}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(approvals)
+}
+
+// handleDeviceApprovalsApprove bulk-approves devices (POST {serials, notes}).
+func handleDeviceApprovalsApprove(w http.ResponseWriter, r *http.Request) {
+	handleDeviceApprovalDecision(w, r, storage.DeviceApprovalApproved)
+}
+
+// handleDeviceApprovalsReject bulk-rejects devices (POST {serials, notes}).
+func handleDeviceApprovalsReject(w http.ResponseWriter, r *http.Request) {
+	handleDeviceApprovalDecision(w, r, storage.DeviceApprovalRejected)
+}
+
+func handleDeviceApprovalDecision(w http.ResponseWriter, r *http.Request, status string) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "POST only", http.StatusMethodNotAllowed)
+		return
+	}
+	var req struct {
+		Serials []string `json:"serials"`
+		Notes   string   `json:"notes"`
+	}
+	if err := decodeJSONBody(r, &req); err != nil {
+		http.Error(w, "invalid JSON", http.StatusBadRequest)
+		return
+	}
+	if len(req.Serials) == 0 {
+		http.Error(w, "serials required", http.StatusBadRequest)
+		return
+	}
+
+	ctx := r.Context()
+	tenantSet := make(map[string]struct{})
+	serials := make([]string, 0, len(req.Serials))
+	for _, serial := range req.Serials {
+		serial = strings.TrimSpace(serial)
+		if serial == "" {
+			continue
+		}
+		approval, err := serverStore.GetDeviceApproval(ctx, serial)
+		if err != nil {
+			logError("Failed to load device approval", "serial", serial, "error", err)
+			http.Error(w, "failed to load device approval", http.StatusInternalServerError)
+			return
+		}
+		if approval == nil {
+			continue
+		}
+		tenantSet[approval.TenantID] = struct{}{}
+		serials = append(serials, serial)
+	}
+	resource := authz.ResourceRef{}
+	for id := range tenantSet {
+		resource.TenantIDs = append(resource.TenantIDs, id)
+	}
+	if !authorizeOrReject(w, r, authz.ActionDevicesApprove, resource) {
+		return
+	}
+
+	principal := getPrincipal(r)
+	reviewedBy := ""
+	if principal != nil && principal.User != nil {
+		reviewedBy = principal.User.Username
+	}
+	updated, err := serverStore.SetDeviceApprovalStatus(ctx, serials, status, reviewedBy, req.Notes)
+	if err != nil {
+		logError("Failed to update device approvals", "status", status, "error", err)
+		http.Error(w, "failed to update device approvals", http.StatusInternalServerError)
+		return
+	}
+
+	logInfo("Device approvals updated", "status", status, "updated", updated, "reviewed_by", reviewedBy)
+	logAuditEntry(ctx, &storage.AuditEntry{
+		ActorType:  storage.AuditActorUser,
+		ActorID:    reviewedBy,
+		ActorName:  reviewedBy,
+		Action:     "devices.approval." + status,
+		TargetType: "device",
+		TargetID:   strings.Join(serials, ","),
+		Details:    fmt.Sprintf("Marked %d device(s) %s", updated, status),
+		Metadata: map[string]interface{}{
+			"serials": serials,
+			"notes":   req.Notes,
+		},
+		IPAddress: extractClientIP(r),
+		UserAgent: r.Header.Get("User-Agent"),
+	})
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"status":  status,
+		"updated": updated,
+	})
+}
+
+// handleDeviceApprovalPolicy reads (GET) or replaces (PUT) a tenant's device
+// approval policy (?tenant_id=).
+func handleDeviceApprovalPolicy(w http.ResponseWriter, r *http.Request) {
+	tenantID := strings.TrimSpace(r.URL.Query().Get("tenant_id"))
+	if tenantID == "" {
+		http.Error(w, "tenant_id required", http.StatusBadRequest)
+		return
+	}
+	resource := authz.ResourceRef{TenantIDs: []string{tenantID}}
+	ctx := r.Context()
+
+	switch r.Method {
+	case http.MethodGet:
+		if !authorizeOrReject(w, r, authz.ActionSettingsFleetRead, resource) {
+			return
+		}
+		policy, err := serverStore.GetDeviceApprovalPolicy(ctx, tenantID)
+		if err != nil {
+			logError("Failed to load device approval policy", "tenant_id", tenantID, "error", err)
+			http.Error(w, "failed to load policy", http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(policy)
+	case http.MethodPut:
+		if !authorizeOrReject(w, r, authz.ActionSettingsFleetWrite, resource) {
+			return
+		}
+		var policy storage.DeviceApprovalPolicy
+		if err := decodeJSONBody(r, &policy); err != nil {
+			http.Error(w, "invalid JSON", http.StatusBadRequest)
+			return
+		}
+		policy.TenantID = tenantID
+		policy.AutoApproveManufacturers = compactPatterns(policy.AutoApproveManufacturers)
+		policy.AutoApproveModels = compactPatterns(policy.AutoApproveModels)
+		if principal := getPrincipal(r); principal != nil && principal.User != nil {
+			policy.UpdatedBy = principal.User.Username
+		}
+		if err := serverStore.UpsertDeviceApprovalPolicy(ctx, &policy); err != nil {
+			logError("Failed to save device approval policy", "tenant_id", tenantID, "error", err)
+			http.Error(w, "failed to save policy", http.StatusInternalServerError)
+			return
+		}
+		logAuditEntry(ctx, &storage.AuditEntry{
+			ActorType:  storage.AuditActorUser,
+			ActorID:    policy.UpdatedBy,
+			ActorName:  policy.UpdatedBy,
+			TenantID:   tenantID,
+			Action:     "devices.approval.policy.update",
+			TargetType: "tenant",
+			TargetID:   tenantID,
+			Details:    fmt.Sprintf("Device approval workflow enabled=%t", policy.Enabled),
+			Metadata: map[string]interface{}{
+				"auto_approve_manufacturers": policy.AutoApproveManufacturers,
+				"auto_approve_models":        policy.AutoApproveModels,
+			},
+			IPAddress: extractClientIP(r),
+			UserAgent: r.Header.Get("User-Agent"),
+		})
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(policy)
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func compactPatterns(patterns []string) []string {
+	out := make([]string, 0, len(patterns))
+	for _, p := range patterns {
+		if p = strings.TrimSpace(p); p != "" {
+			out = append(out, p)
+		}
+	}
+	return out
+}
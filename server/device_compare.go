@@ -0,0 +1,285 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	authz "printmaster/server/authz"
+	"printmaster/server/reports"
+	"printmaster/server/storage"
+)
+
+const (
+	// compareMaxDevices bounds one comparison so the table stays readable.
+	compareMaxDevices = 8
+	// compareDefaultDays is the comparison window when ?days= is omitted.
+	compareDefaultDays = 30
+	compareMaxDays     = 365
+)
+
+// compareSupplies are the toner keys priced from the baseline library.
+var compareSupplies = []string{"black", "cyan", "magenta", "yellow"}
+
+// DeviceComparison is one device's column in a side-by-side comparison.
+// Volumes are counter deltas over the window; uptime is the share of the
+// window not covered by device_offline alerts; jams are paper_jam alerts.
+// Supply cost uses cartridge prices from the yield baseline library.
+type DeviceComparison struct {
+	Serial       string    `json:"serial"`
+	Manufacturer string    `json:"manufacturer,omitempty"`
+	Model        string    `json:"model,omitempty"`
+	Location     string    `json:"location,omitempty"`
+	AssetNumber  string    `json:"asset_number,omitempty"`
+	IP           string    `json:"ip,omitempty"`
+	TenantID     string    `json:"tenant_id,omitempty"`
+	AgentID      string    `json:"agent_id,omitempty"`
+	FirstSeen    time.Time `json:"first_seen,omitempty"`
+	LastSeen     time.Time `json:"last_seen,omitempty"`
+
+	// ObservedDays is the part of the window covered by metrics.
+	ObservedDays float64 `json:"observed_days"`
+	Pages        int64   `json:"pages"`
+	MonoPages    int64   `json:"mono_pages"`
+	ColorPages   int64   `json:"color_pages"`
+	Scans        int64   `json:"scans"`
+	PagesPerDay  float64 `json:"pages_per_day"`
+	// CounterReset is set when a counter went backwards in the window; the
+	// affected volume is reported as 0.
+	CounterReset bool `json:"counter_reset,omitempty"`
+
+	UptimePct       float64 `json:"uptime_pct"`
+	DowntimeMinutes int64   `json:"downtime_minutes"`
+	OfflineEvents   int     `json:"offline_events"`
+
+	Jams            int      `json:"jams"`
+	JamsPer10kPages *float64 `json:"jams_per_10k_pages,omitempty"`
+
+	SupplyCost       *float64 `json:"supply_cost,omitempty"`
+	CostPerPage      *float64 `json:"cost_per_page,omitempty"`
+	SuppliesPriced   []string `json:"supplies_priced,omitempty"`
+	SuppliesUnpriced []string `json:"supplies_unpriced,omitempty"`
+}
+
+// DeviceComparisonResult is the response of /api/v1/devices/compare.
+type DeviceComparisonResult struct {
+	From    time.Time          `json:"from"`
+	To      time.Time          `json:"to"`
+	Days    int                `json:"days"`
+	Devices []DeviceComparison `json:"devices"`
+}
+
+// handleDeviceCompare serves GET /api/v1/devices/compare?serials=A,B[&days=30].
+// Serials may also be repeated as ?serial=A&serial=B. Between 2 and
+// compareMaxDevices devices, all visible to the caller, are compared.
+func handleDeviceCompare(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "GET only", http.StatusMethodNotAllowed)
+		return
+	}
+	ctx := r.Context()
+	q := r.URL.Query()
+
+	var serials []string
+	seen := map[string]bool{}
+	for _, raw := range append(q["serial"], strings.Split(q.Get("serials"), ",")...) {
+		serial := strings.TrimSpace(raw)
+		if serial == "" || seen[serial] {
+			continue
+		}
+		seen[serial] = true
+		serials = append(serials, serial)
+	}
+	if len(serials) < 2 || len(serials) > compareMaxDevices {
+		http.Error(w, fmt.Sprintf("between 2 and %d serials required", compareMaxDevices), http.StatusBadRequest)
+		return
+	}
+	days := compareDefaultDays
+	if v := strings.TrimSpace(q.Get("days")); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > compareMaxDays {
+			http.Error(w, fmt.Sprintf("days must be between 1 and %d", compareMaxDays), http.StatusBadRequest)
+			return
+		}
+		days = n
+	}
+
+	devices := make([]*storage.Device, 0, len(serials))
+	tenants := make([]string, 0, len(serials))
+	for _, serial := range serials {
+		device, tenantID, ok := resolveNoteDevice(ctx, r, serial)
+		if !ok {
+			http.Error(w, "device not found: "+serial, http.StatusNotFound)
+			return
+		}
+		devices = append(devices, device)
+		tenants = append(tenants, tenantID)
+	}
+	if !authorizeOrReject(w, r, authz.ActionDevicesRead, authz.ResourceRef{TenantIDs: tenants}) {
+		return
+	}
+
+	to := time.Now().UTC()
+	result, err := compareDevices(ctx, serverStore, devices, tenants, to.AddDate(0, 0, -days), to)
+	if err != nil {
+		logError("Failed to compare devices", "serials", strings.Join(serials, ","), "error", err)
+		http.Error(w, "failed to compare devices", http.StatusInternalServerError)
+		return
+	}
+	result.Days = days
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
+}
+
+// compareDevices builds the comparison for devices over [from, to]. tenants
+// holds each device's tenant ID.
+func compareDevices(ctx context.Context, store storage.Store, devices []*storage.Device, tenants []string, from, to time.Time) (*DeviceComparisonResult, error) {
+	baselines, err := store.ListYieldBaselines(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("list yield baselines: %w", err)
+	}
+	// Offline alerts may have started before the window, so they are not
+	// limited by trigger time; jams are.
+	offline, err := store.ListAlerts(ctx, storage.AlertFilters{Type: storage.AlertTypeDeviceOffline})
+	if err != nil {
+		return nil, fmt.Errorf("list offline alerts: %w", err)
+	}
+	jams, err := store.ListAlerts(ctx, storage.AlertFilters{Type: storage.AlertTypePaperJam, StartTime: &from, EndTime: &to})
+	if err != nil {
+		return nil, fmt.Errorf("list jam alerts: %w", err)
+	}
+
+	result := &DeviceComparisonResult{From: from, To: to, Devices: make([]DeviceComparison, 0, len(devices))}
+	for i, device := range devices {
+		c := DeviceComparison{
+			Serial:       device.Serial,
+			Manufacturer: device.Manufacturer,
+			Model:        device.Model,
+			Location:     device.Location,
+			AssetNumber:  device.AssetNumber,
+			IP:           device.IP,
+			TenantID:     tenants[i],
+			AgentID:      device.AgentID,
+			FirstSeen:    device.FirstSeen,
+			LastSeen:     device.LastSeen,
+		}
+
+		start, err := store.GetMetricsAtOrBefore(ctx, device.Serial, from)
+		if err != nil {
+			return nil, fmt.Errorf("metrics at window start for %s: %w", device.Serial, err)
+		}
+		if start == nil {
+			history, err := store.GetMetricsHistory(ctx, device.Serial, from)
+			if err != nil {
+				return nil, fmt.Errorf("metrics history for %s: %w", device.Serial, err)
+			}
+			start = earliestSnapshot(history)
+		}
+		end, err := store.GetLatestMetrics(ctx, device.Serial)
+		if err != nil {
+			return nil, fmt.Errorf("latest metrics for %s: %w", device.Serial, err)
+		}
+		if start != nil && end != nil && end.Timestamp.After(start.Timestamp) {
+			c.compareVolumes(start, end, from)
+			c.compareSupplyCost(baselines, device, start, end)
+		}
+
+		c.compareUptime(offline, device, from, to)
+		for _, a := range jams {
+			if a != nil && a.DeviceSerial == device.Serial {
+				c.Jams++
+			}
+		}
+		if c.Pages > 0 {
+			c.JamsPer10kPages = roundedPtr(float64(c.Jams)*10000/float64(c.Pages), 2)
+		}
+		result.Devices = append(result.Devices, c)
+	}
+	return result, nil
+}
+
+func (c *DeviceComparison) compareVolumes(start, end *storage.MetricsSnapshot, from time.Time) {
+	delta := func(a, b int) int64 {
+		if b < a {
+			c.CounterReset = true
+			return 0
+		}
+		return int64(b - a)
+	}
+	c.Pages = delta(start.PageCount, end.PageCount)
+	c.MonoPages = delta(start.MonoPages, end.MonoPages)
+	c.ColorPages = delta(start.ColorPages, end.ColorPages)
+	c.Scans = delta(start.ScanCount, end.ScanCount)
+
+	observedFrom := start.Timestamp
+	if observedFrom.Before(from) {
+		observedFrom = from
+	}
+	c.ObservedDays = math.Round(end.Timestamp.Sub(observedFrom).Hours()/24*10) / 10
+	if days := end.Timestamp.Sub(observedFrom).Hours() / 24; days > 0 {
+		c.PagesPerDay = math.Round(float64(c.Pages)/days*10) / 10
+	}
+}
+
+// compareSupplyCost prices each toner the device reports with the matching
+// baseline's cost per page and the pages printed against that toner.
+func (c *DeviceComparison) compareSupplyCost(baselines []storage.YieldBaseline, device *storage.Device, start, end *storage.MetricsSnapshot) {
+	reported := map[string]bool{}
+	for key := range end.TonerLevels {
+		reported[strings.ToLower(key)] = true
+	}
+	total, priced := 0.0, false
+	for _, supply := range compareSupplies {
+		if !reported[supply] {
+			continue
+		}
+		b, ok := reports.MatchYieldBaseline(baselines, device.Model, supply, device.Consumables)
+		if !ok || b.CostPerPage() <= 0 {
+			c.SuppliesUnpriced = append(c.SuppliesUnpriced, supply)
+			continue
+		}
+		pages := reports.SupplyCounter(end, supply) - reports.SupplyCounter(start, supply)
+		if pages > 0 {
+			total += b.CostPerPage() * float64(pages)
+		}
+		c.SuppliesPriced = append(c.SuppliesPriced, supply)
+		priced = true
+	}
+	if !priced {
+		return
+	}
+	c.SupplyCost = roundedPtr(total, 2)
+	if c.Pages > 0 {
+		c.CostPerPage = roundedPtr(total/float64(c.Pages), 4)
+	}
+}
+
+// compareUptime subtracts the device's offline alert periods from the part
+// of the window after the device was first seen.
+func (c *DeviceComparison) compareUptime(offline []*storage.Alert, device *storage.Device, from, to time.Time) {
+	dt := reports.OfflineDowntime(offline, device.Serial, device.FirstSeen, from, to)
+	c.OfflineEvents = dt.Events
+	c.DowntimeMinutes = int64(dt.Down.Minutes())
+	c.UptimePct = dt.UptimePct()
+}
+
+func earliestSnapshot(history []*storage.MetricsSnapshot) *storage.MetricsSnapshot {
+	var first *storage.MetricsSnapshot
+	for _, m := range history {
+		if m != nil && (first == nil || m.Timestamp.Before(first.Timestamp)) {
+			first = m
+		}
+	}
+	return first
+}
+
+func roundedPtr(v float64, places int) *float64 {
+	p := math.Pow(10, float64(places))
+	r := math.Round(v*p) / p
+	return &r
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"printmaster/server/storage"
+)
+
+func TestHandleDeviceCompare(t *testing.T) {
+	store := SetupTestStore(t)
+	ctx := context.Background()
+	now := time.Now().UTC()
+
+	for _, a := range []*storage.Agent{
+		{AgentID: "agent-a", Hostname: "a", Token: "ta", TenantID: "tenant-a", Status: "active", RegisteredAt: now, LastSeen: now},
+		{AgentID: "agent-b", Hostname: "b", Token: "tb", TenantID: "tenant-b", Status: "active", RegisteredAt: now, LastSeen: now},
+	} {
+		if err := store.RegisterAgent(ctx, a); err != nil {
+			t.Fatalf("RegisterAgent: %v", err)
+		}
+	}
+	for _, d := range []struct{ serial, agentID, model string }{
+		{"SN-1", "agent-a", "LaserJet M404dn"},
+		{"SN-2", "agent-a", "LaserJet M404dn"},
+		{"SN-3", "agent-b", "LaserJet M404dn"},
+	} {
+		dev := &storage.Device{}
+		dev.Serial, dev.AgentID, dev.Model = d.serial, d.agentID, d.model
+		dev.LastSeen, dev.FirstSeen = now, now.AddDate(0, 0, -90)
+		if err := store.UpsertDevice(ctx, dev); err != nil {
+			t.Fatalf("UpsertDevice: %v", err)
+		}
+	}
+	for _, m := range []*storage.MetricsSnapshot{
+		{Serial: "SN-1", AgentID: "agent-a", Timestamp: now.AddDate(0, 0, -40), PageCount: 10000, TonerLevels: map[string]interface{}{"black": 90}},
+		{Serial: "SN-1", AgentID: "agent-a", Timestamp: now.Add(-time.Hour), PageCount: 30000, TonerLevels: map[string]interface{}{"black": 40}},
+		{Serial: "SN-2", AgentID: "agent-a", Timestamp: now.AddDate(0, 0, -10), PageCount: 500, TonerLevels: map[string]interface{}{"black": 80}},
+		{Serial: "SN-2", AgentID: "agent-a", Timestamp: now.Add(-time.Hour), PageCount: 1500, TonerLevels: map[string]interface{}{"black": 75}},
+	} {
+		if err := store.SaveMetrics(ctx, m); err != nil {
+			t.Fatalf("SaveMetrics: %v", err)
+		}
+	}
+	if err := store.ReplaceYieldBaselines(ctx, []storage.YieldBaseline{
+		{PrinterModel: "LaserJet M404dn", Supply: "black", RatedYield: 10000, CartridgeCost: 200},
+	}); err != nil {
+		t.Fatalf("ReplaceYieldBaselines: %v", err)
+	}
+	for _, a := range []storage.Alert{
+		{Type: storage.AlertTypePaperJam, DeviceSerial: "SN-1", TriggeredAt: now.AddDate(0, 0, -5)},
+		{Type: storage.AlertTypePaperJam, DeviceSerial: "SN-1", TriggeredAt: now.AddDate(0, 0, -2)},
+		{Type: storage.AlertTypePaperJam, DeviceSerial: "SN-1", TriggeredAt: now.AddDate(0, 0, -60)}, // before window
+		{Type: storage.AlertTypeDeviceOffline, DeviceSerial: "SN-2", TriggeredAt: now.Add(-36 * time.Hour)},
+	} {
+		a.Severity, a.Scope, a.Status, a.Title, a.TenantID = "warning", "device", string(storage.AlertStatusActive), "alert", "tenant-a"
+		if _, err := store.CreateAlert(ctx, &a); err != nil {
+			t.Fatalf("CreateAlert: %v", err)
+		}
+	}
+
+	call := func(user *storage.User, query string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/devices/compare"+query, nil)
+		rr := httptest.NewRecorder()
+		handleDeviceCompare(rr, InjectTestUser(req, user))
+		return rr
+	}
+
+	admin := NewTestUser(storage.RoleAdmin)
+	if rr := call(admin, "?serials=SN-1"); rr.Code != http.StatusBadRequest {
+		t.Errorf("single serial: expected 400, got %d", rr.Code)
+	}
+	if rr := call(admin, "?serials=SN-1,SN-2&days=0"); rr.Code != http.StatusBadRequest {
+		t.Errorf("days=0: expected 400, got %d", rr.Code)
+	}
+	if rr := call(admin, "?serials=SN-1,SN-404"); rr.Code != http.StatusNotFound {
+		t.Errorf("unknown serial: expected 404, got %d", rr.Code)
+	}
+	if rr := call(NewTestUser(storage.RoleViewer, "tenant-a"), "?serials=SN-1,SN-3"); rr.Code != http.StatusNotFound {
+		t.Errorf("other tenant's device: expected 404, got %d", rr.Code)
+	}
+
+	rr := call(NewTestUser(storage.RoleViewer, "tenant-a"), "?serial=SN-1&serial=SN-2&days=30")
+	if rr.Code != http.StatusOK {
+		t.Fatalf("compare: expected 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	var res DeviceComparisonResult
+	if err := json.Unmarshal(rr.Body.Bytes(), &res); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if res.Days != 30 || len(res.Devices) != 2 {
+		t.Fatalf("result = %+v", res)
+	}
+	a, b := res.Devices[0], res.Devices[1]
+	if a.Serial != "SN-1" || a.Pages != 20000 || a.Jams != 2 || a.JamsPer10kPages == nil || *a.JamsPer10kPages != 1 {
+		t.Errorf("SN-1 = %+v", a)
+	}
+	if a.SupplyCost == nil || *a.SupplyCost != 400 || a.CostPerPage == nil || *a.CostPerPage != 0.02 {
+		t.Errorf("SN-1 supply cost = %v per page %v", a.SupplyCost, a.CostPerPage)
+	}
+	if a.UptimePct != 100 {
+		t.Errorf("SN-1 uptime = %v, want 100", a.UptimePct)
+	}
+	if b.Pages != 1000 || b.OfflineEvents != 1 || b.UptimePct < 94.9 || b.UptimePct > 95.1 {
+		t.Errorf("SN-2 = %+v", b)
+	}
+}
+
+func TestCompareUptimeMergesOverlaps(t *testing.T) {
+	to := time.Date(2026, 3, 11, 0, 0, 0, 0, time.UTC)
+	from := to.AddDate(0, 0, -10)
+	resolved := from.Add(30 * time.Hour)
+	device := &storage.Device{}
+	device.Serial = "SN-1"
+
+	offline := []*storage.Alert{
+		// Started before the window, resolved 30h in
+		{DeviceSerial: "SN-1", TriggeredAt: from.Add(-time.Hour), ResolvedAt: &resolved},
+		// Overlaps the first one
+		{DeviceSerial: "SN-1", TriggeredAt: from.Add(20 * time.Hour), ResolvedAt: &resolved},
+		{DeviceSerial: "SN-2", TriggeredAt: from},
+	}
+	var c DeviceComparison
+	c.compareUptime(offline, device, from, to)
+	if c.DowntimeMinutes != 30*60 || c.OfflineEvents != 2 || c.UptimePct != 87.5 {
+		t.Errorf("uptime = %+v", c)
+	}
+}
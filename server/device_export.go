@@ -0,0 +1,399 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	authz "printmaster/server/authz"
+	"printmaster/server/reports"
+	"printmaster/server/storage"
+)
+
+const (
+	// exportDefaultDays is the metrics export window when ?since= is omitted.
+	exportDefaultDays = 30
+	// exportMaxDays bounds one metrics export so a request can't pull the
+	// whole history table into memory.
+	exportMaxDays = 366
+)
+
+var (
+	deviceExportColumns = []string{
+		"serial", "tenant_id", "tenant_name", "agent_id", "agent_name", "ip", "manufacturer", "model",
+		"hostname", "mac_address", "firmware", "asset_number", "location", "status", "first_seen", "last_seen",
+		"page_count", "mono_pages", "color_pages", "scan_count", "last_metrics_at", "toner_levels",
+	}
+	metricsExportColumns = []string{
+		"serial", "tenant_id", "agent_id", "timestamp", "page_count", "mono_pages", "color_pages", "scan_count",
+		"duplex_sheets", "simplex_sheets", "nup_sheets", "toner_levels",
+	}
+)
+
+// exportDevice is a device visible to the caller, with the agent and
+// tenant it was resolved through.
+type exportDevice struct {
+	device *storage.Device
+	agent  *storage.Agent
+	tenant *storage.Tenant
+}
+
+// deviceExportFilter holds the device filters shared by both exports.
+// Empty fields match everything.
+type deviceExportFilter struct {
+	tenantIDs    []string
+	agentID      string
+	siteID       string
+	serial       string
+	manufacturer string
+	model        string
+	status       string
+	query        string
+}
+
+func parseDeviceExportFilter(r *http.Request) deviceExportFilter {
+	q := r.URL.Query()
+	return deviceExportFilter{
+		tenantIDs:    billingTenantIDs(r),
+		agentID:      strings.TrimSpace(q.Get("agent_id")),
+		siteID:       strings.TrimSpace(q.Get("site_id")),
+		serial:       strings.TrimSpace(q.Get("serial")),
+		manufacturer: strings.TrimSpace(q.Get("manufacturer")),
+		model:        strings.TrimSpace(q.Get("model")),
+		status:       strings.ToLower(strings.TrimSpace(q.Get("status"))),
+		query:        strings.ToLower(strings.TrimSpace(q.Get("q"))),
+	}
+}
+
+func (f deviceExportFilter) matchAgent(a *storage.Agent) bool {
+	if f.agentID != "" && a.AgentID != f.agentID {
+		return false
+	}
+	if len(f.tenantIDs) > 0 && !slices.Contains(f.tenantIDs, a.TenantID) {
+		return false
+	}
+	return f.siteID == "" || slices.Contains(a.SiteIDs, f.siteID)
+}
+
+func (f deviceExportFilter) matchDevice(d *storage.Device) bool {
+	if f.serial != "" && d.Serial != f.serial {
+		return false
+	}
+	if f.manufacturer != "" && !strings.EqualFold(d.Manufacturer, f.manufacturer) {
+		return false
+	}
+	if f.model != "" && !strings.EqualFold(d.Model, f.model) {
+		return false
+	}
+	if f.status != "" && string(deviceStatusState(d)) != f.status {
+		return false
+	}
+	if f.query == "" {
+		return true
+	}
+	for _, field := range []string{d.Serial, d.IP, d.Hostname, d.Manufacturer, d.Model, d.AssetNumber, d.Location} {
+		if strings.Contains(strings.ToLower(field), f.query) {
+			return true
+		}
+	}
+	return false
+}
+
+// resolveExportDevices returns the devices matching filter, limited to the
+// tenants in scope (nil scope = all tenants), ordered by serial. Devices are
+// scoped through the tenant of the agent that reported them.
+func resolveExportDevices(ctx context.Context, scope map[string]struct{}, filter deviceExportFilter) ([]exportDevice, error) {
+	agents, err := serverStore.ListAgents(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("list agents: %w", err)
+	}
+	tenants, err := serverStore.ListTenants(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("list tenants: %w", err)
+	}
+	tenantByID := make(map[string]*storage.Tenant, len(tenants))
+	for _, t := range tenants {
+		if t != nil {
+			tenantByID[t.ID] = t
+		}
+	}
+	agentByID := make(map[string]*storage.Agent, len(agents))
+	for _, a := range agents {
+		if a != nil && tenantAllowed(scope, a.TenantID) && filter.matchAgent(a) {
+			agentByID[a.AgentID] = a
+		}
+	}
+	if len(agentByID) == 0 {
+		return nil, nil
+	}
+
+	devices, err := serverStore.ListAllDevices(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("list devices: %w", err)
+	}
+	var out []exportDevice
+	for _, d := range devices {
+		if d == nil {
+			continue
+		}
+		agent, ok := agentByID[d.AgentID]
+		if !ok || !filter.matchDevice(d) {
+			continue
+		}
+		out = append(out, exportDevice{device: d, agent: agent, tenant: tenantByID[agent.TenantID]})
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].device.Serial < out[j].device.Serial })
+	return out, nil
+}
+
+// exportScope authorizes an export and returns the caller's tenant scope.
+// Explicitly requested tenants must all be in scope.
+func exportScope(w http.ResponseWriter, r *http.Request, action authz.Action, filter deviceExportFilter) (map[string]struct{}, bool) {
+	if !authorizeOrReject(w, r, action, authz.ResourceRef{TenantIDs: filter.tenantIDs}) {
+		return nil, false
+	}
+	principal := getPrincipal(r)
+	if principal == nil {
+		http.Error(w, "unauthenticated", http.StatusUnauthorized)
+		return nil, false
+	}
+	scope, ok := tenantScope(principal)
+	if !ok {
+		http.Error(w, "forbidden", http.StatusForbidden)
+		return nil, false
+	}
+	for _, id := range filter.tenantIDs {
+		if !tenantAllowed(scope, id) {
+			http.Error(w, "forbidden", http.StatusForbidden)
+			return nil, false
+		}
+	}
+	return scope, true
+}
+
+// exportFormat validates ?format=, defaulting to CSV.
+func exportFormat(r *http.Request) (storage.ReportFormat, bool) {
+	switch strings.ToLower(strings.TrimSpace(r.URL.Query().Get("format"))) {
+	case "", "csv":
+		return storage.ReportFormatCSV, true
+	case "xlsx":
+		return storage.ReportFormatXLSX, true
+	}
+	return "", false
+}
+
+// handleDevicesExport serves GET /api/v1/devices/export: the device
+// inventory with the latest counters and supply levels, as CSV or XLSX.
+func handleDevicesExport(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "GET only", http.StatusMethodNotAllowed)
+		return
+	}
+	format, ok := exportFormat(r)
+	if !ok {
+		http.Error(w, "format must be csv or xlsx", http.StatusBadRequest)
+		return
+	}
+	filter := parseDeviceExportFilter(r)
+	scope, ok := exportScope(w, r, authz.ActionDevicesRead, filter)
+	if !ok {
+		return
+	}
+
+	ctx := r.Context()
+	devices, err := resolveExportDevices(ctx, scope, filter)
+	if err != nil {
+		logError("Failed to resolve devices for export", "error", err)
+		http.Error(w, "failed to export devices", http.StatusInternalServerError)
+		return
+	}
+	serials := make([]string, 0, len(devices))
+	for _, d := range devices {
+		serials = append(serials, d.device.Serial)
+	}
+	latest := map[string]*storage.MetricsSnapshot{}
+	if len(serials) > 0 {
+		if latest, err = serverStore.GetLatestMetricsBatch(ctx, serials); err != nil {
+			logError("Failed to fetch latest metrics for export", "error", err)
+			http.Error(w, "failed to export devices", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	rows := make([]map[string]any, 0, len(devices))
+	for _, ed := range devices {
+		d := ed.device
+		row := map[string]any{
+			"serial":       d.Serial,
+			"tenant_id":    ed.agent.TenantID,
+			"tenant_name":  "",
+			"agent_id":     d.AgentID,
+			"agent_name":   ed.agent.Hostname,
+			"ip":           d.IP,
+			"manufacturer": d.Manufacturer,
+			"model":        d.Model,
+			"hostname":     d.Hostname,
+			"mac_address":  d.MACAddress,
+			"firmware":     d.Firmware,
+			"asset_number": d.AssetNumber,
+			"location":     d.Location,
+			"status":       string(deviceStatusState(d)),
+			"first_seen":   d.FirstSeen,
+			"last_seen":    d.LastSeen,
+		}
+		if ed.agent.Name != "" {
+			row["agent_name"] = ed.agent.Name
+		}
+		if ed.tenant != nil {
+			row["tenant_name"] = ed.tenant.Name
+		}
+		if m := latest[d.Serial]; m != nil {
+			row["page_count"] = m.PageCount
+			row["mono_pages"] = m.MonoPages
+			row["color_pages"] = m.ColorPages
+			row["scan_count"] = m.ScanCount
+			row["last_metrics_at"] = m.Timestamp
+			row["toner_levels"] = m.TonerLevels
+		}
+		rows = append(rows, row)
+	}
+	writeExport(w, format, "devices-"+time.Now().UTC().Format("20060102"), deviceExportColumns, rows)
+}
+
+// handleMetricsExport serves GET /api/v1/metrics/export: every stored
+// metrics reading in [since, until) for the matching devices, as CSV or
+// XLSX. Readings already rolled into archives are not included.
+func handleMetricsExport(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "GET only", http.StatusMethodNotAllowed)
+		return
+	}
+	format, ok := exportFormat(r)
+	if !ok {
+		http.Error(w, "format must be csv or xlsx", http.StatusBadRequest)
+		return
+	}
+	until := time.Now().UTC()
+	if v := r.URL.Query().Get("until"); v != "" {
+		t, err := parseExportTime(v)
+		if err != nil {
+			http.Error(w, "until must be RFC3339 or YYYY-MM-DD", http.StatusBadRequest)
+			return
+		}
+		until = t
+	}
+	since := until.AddDate(0, 0, -exportDefaultDays)
+	if v := r.URL.Query().Get("since"); v != "" {
+		t, err := parseExportTime(v)
+		if err != nil {
+			http.Error(w, "since must be RFC3339 or YYYY-MM-DD", http.StatusBadRequest)
+			return
+		}
+		since = t
+	}
+	if !since.Before(until) || until.Sub(since) > exportMaxDays*24*time.Hour {
+		http.Error(w, fmt.Sprintf("since must be before until and at most %d days earlier", exportMaxDays), http.StatusBadRequest)
+		return
+	}
+	filter := parseDeviceExportFilter(r)
+	scope, ok := exportScope(w, r, authz.ActionMetricsHistoryRead, filter)
+	if !ok {
+		return
+	}
+
+	ctx := r.Context()
+	devices, err := resolveExportDevices(ctx, scope, filter)
+	if err != nil {
+		logError("Failed to resolve devices for metrics export", "error", err)
+		http.Error(w, "failed to export metrics", http.StatusInternalServerError)
+		return
+	}
+	tenantBySerial := make(map[string]string, len(devices))
+	for _, d := range devices {
+		tenantBySerial[d.device.Serial] = d.agent.TenantID
+	}
+	var snapshots []*storage.MetricsSnapshot
+	if len(devices) > 0 {
+		if snapshots, err = serverStore.ListMetricsInRange(ctx, since, until); err != nil {
+			logError("Failed to list metrics for export", "error", err)
+			http.Error(w, "failed to export metrics", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	rows := make([]map[string]any, 0, len(snapshots))
+	for _, m := range snapshots {
+		tenantID, ok := tenantBySerial[m.Serial]
+		if !ok {
+			continue
+		}
+		rows = append(rows, map[string]any{
+			"serial":         m.Serial,
+			"tenant_id":      tenantID,
+			"agent_id":       m.AgentID,
+			"timestamp":      m.Timestamp,
+			"page_count":     m.PageCount,
+			"mono_pages":     m.MonoPages,
+			"color_pages":    m.ColorPages,
+			"scan_count":     m.ScanCount,
+			"duplex_sheets":  m.DuplexSheets,
+			"simplex_sheets": m.SimplexSheets,
+			"nup_sheets":     m.NUpSheets,
+			"toner_levels":   m.TonerLevels,
+		})
+	}
+	name := fmt.Sprintf("metrics-%s-%s", since.Format("20060102"), until.Format("20060102"))
+	writeExport(w, format, name, metricsExportColumns, rows)
+}
+
+// parseExportTime accepts RFC3339 timestamps or plain dates (UTC midnight).
+func parseExportTime(v string) (time.Time, error) {
+	v = strings.TrimSpace(v)
+	if t, err := time.Parse("2006-01-02", v); err == nil {
+		return t, nil
+	}
+	return parseOptionalRFC3339(v)
+}
+
+// writeExport renders rows through the report formatter so exports match
+// report downloads column for column (toner_levels expands into toner_*
+// columns). An empty export is still a valid file with a header row.
+func writeExport(w http.ResponseWriter, format storage.ReportFormat, name string, columns []string, rows []map[string]any) {
+	if len(rows) == 0 {
+		// Without rows there are no supply keys to expand toner_levels into
+		columns = columns[:len(columns)-1]
+		rows = []map[string]any{}
+	}
+	result := &reports.GenerateResult{Columns: columns, Rows: rows, RowCount: len(rows)}
+
+	formatter := reports.NewFormatter()
+	var (
+		data        []byte
+		err         error
+		contentType string
+	)
+	switch format {
+	case storage.ReportFormatXLSX:
+		data, err = formatter.FormatXLSX(result)
+		contentType = "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+	default:
+		if len(rows) == 0 {
+			data = []byte(strings.Join(columns, ",") + "\n")
+		} else {
+			data, err = formatter.FormatCSV(result)
+		}
+		contentType = "text/csv"
+	}
+	if err != nil {
+		logError("Failed to format export", "name", name, "error", err)
+		http.Error(w, "failed to format export", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", name+"."+string(format)))
+	w.Write(data)
+}
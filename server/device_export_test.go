@@ -0,0 +1,159 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/csv"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"printmaster/server/storage"
+)
+
+func seedExportFleet(t *testing.T) time.Time {
+	t.Helper()
+	store := SetupTestStore(t)
+	ctx := context.Background()
+	now := time.Now().UTC().Truncate(time.Second)
+	for _, tenant := range []*storage.Tenant{{ID: "tenant-a", Name: "Tenant A"}, {ID: "tenant-b", Name: "Tenant B"}} {
+		if err := store.CreateTenant(ctx, tenant); err != nil {
+			t.Fatalf("CreateTenant: %v", err)
+		}
+	}
+	for _, a := range []*storage.Agent{
+		{AgentID: "agent-a", Hostname: "a", Token: "ta", TenantID: "tenant-a", Status: "active", RegisteredAt: now, LastSeen: now},
+		{AgentID: "agent-b", Hostname: "b", Token: "tb", TenantID: "tenant-b", Status: "active", RegisteredAt: now, LastSeen: now},
+	} {
+		if err := store.RegisterAgent(ctx, a); err != nil {
+			t.Fatalf("RegisterAgent: %v", err)
+		}
+	}
+	for _, d := range []struct{ serial, agentID, manufacturer string }{
+		{"SN-A1", "agent-a", "HP"},
+		{"SN-A2", "agent-a", "Canon"},
+		{"SN-B1", "agent-b", "HP"},
+	} {
+		dev := &storage.Device{}
+		dev.Serial, dev.AgentID, dev.Manufacturer, dev.LastSeen = d.serial, d.agentID, d.manufacturer, now
+		if err := store.UpsertDevice(ctx, dev); err != nil {
+			t.Fatalf("UpsertDevice: %v", err)
+		}
+		for i, ts := range []time.Time{now.AddDate(0, 0, -40), now.Add(-2 * time.Hour), now.Add(-time.Hour)} {
+			m := &storage.MetricsSnapshot{
+				Serial: d.serial, AgentID: d.agentID, Timestamp: ts,
+				PageCount: 1000 + i*100, DuplexSheets: 200 + i*10,
+				TonerLevels: map[string]interface{}{"black": 80 - i},
+			}
+			if err := store.SaveMetrics(ctx, m); err != nil {
+				t.Fatalf("SaveMetrics: %v", err)
+			}
+		}
+	}
+	return now
+}
+
+func runExport(t *testing.T, handler http.HandlerFunc, url string, user *storage.User) *httptest.ResponseRecorder {
+	t.Helper()
+	req := InjectTestUser(httptest.NewRequest(http.MethodGet, url, nil), user)
+	rr := httptest.NewRecorder()
+	handler(rr, req)
+	return rr
+}
+
+func readExportCSV(t *testing.T, rr *httptest.ResponseRecorder) []map[string]string {
+	t.Helper()
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	records, err := csv.NewReader(rr.Body).ReadAll()
+	if err != nil {
+		t.Fatalf("parse CSV: %v", err)
+	}
+	var rows []map[string]string
+	for _, rec := range records[1:] {
+		row := map[string]string{}
+		for i, col := range records[0] {
+			row[col] = rec[i]
+		}
+		rows = append(rows, row)
+	}
+	return rows
+}
+
+func TestHandleDevicesExportCSV(t *testing.T) {
+	seedExportFleet(t)
+	admin := NewTestUser(storage.RoleAdmin)
+
+	rows := readExportCSV(t, runExport(t, handleDevicesExport, "/api/v1/devices/export", admin))
+	if len(rows) != 3 || rows[0]["serial"] != "SN-A1" || rows[0]["tenant_name"] != "Tenant A" {
+		t.Fatalf("unexpected rows: %v", rows)
+	}
+	if rows[0]["page_count"] != "1200" || rows[0]["toner_black"] != "78" {
+		t.Errorf("latest counters missing: %v", rows[0])
+	}
+
+	rows = readExportCSV(t, runExport(t, handleDevicesExport, "/api/v1/devices/export?manufacturer=hp", admin))
+	if len(rows) != 2 {
+		t.Errorf("manufacturer filter: got %d rows", len(rows))
+	}
+
+	// Tenant-scoped users only see their own devices and can't ask for others
+	viewer := NewTestUser(storage.RoleViewer, "tenant-a")
+	rows = readExportCSV(t, runExport(t, handleDevicesExport, "/api/v1/devices/export", viewer))
+	for _, row := range rows {
+		if row["tenant_id"] != "tenant-a" {
+			t.Errorf("scoped export leaked %v", row)
+		}
+	}
+	if len(rows) != 2 {
+		t.Errorf("scoped export: got %d rows", len(rows))
+	}
+	if rr := runExport(t, handleDevicesExport, "/api/v1/devices/export?tenant_id=tenant-b", viewer); rr.Code != http.StatusForbidden {
+		t.Errorf("foreign tenant: expected 403, got %d", rr.Code)
+	}
+
+	rr := runExport(t, handleDevicesExport, "/api/v1/devices/export?q=nothing-matches", admin)
+	if body := rr.Body.String(); rr.Code != http.StatusOK || !strings.HasPrefix(body, "serial,tenant_id,") || strings.Count(body, "\n") != 1 {
+		t.Errorf("empty export should be a header row, got %d %q", rr.Code, body)
+	}
+	if rr := runExport(t, handleDevicesExport, "/api/v1/devices/export?format=pdf", admin); rr.Code != http.StatusBadRequest {
+		t.Errorf("unknown format: expected 400, got %d", rr.Code)
+	}
+}
+
+func TestHandleMetricsExport(t *testing.T) {
+	seedExportFleet(t)
+	admin := NewTestUser(storage.RoleAdmin)
+
+	rows := readExportCSV(t, runExport(t, handleMetricsExport, "/api/v1/metrics/export?tenant_id=tenant-a", admin))
+	if len(rows) != 4 {
+		t.Fatalf("expected 2 readings for each tenant-a device in the last 30 days, got %d: %v", len(rows), rows)
+	}
+	if rows[0]["serial"] != "SN-A1" || rows[0]["duplex_sheets"] != "210" || rows[0]["tenant_id"] != "tenant-a" {
+		t.Errorf("unexpected first row: %v", rows[0])
+	}
+
+	rows = readExportCSV(t, runExport(t, handleMetricsExport, "/api/v1/metrics/export?serial=SN-B1&since="+time.Now().UTC().AddDate(0, 0, -60).Format("2006-01-02"), admin))
+	if len(rows) != 3 {
+		t.Errorf("since filter: got %d rows", len(rows))
+	}
+
+	rr := runExport(t, handleMetricsExport, "/api/v1/metrics/export?format=xlsx", admin)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("xlsx export: %d %s", rr.Code, rr.Body.String())
+	}
+	if _, err := zip.NewReader(bytes.NewReader(rr.Body.Bytes()), int64(rr.Body.Len())); err != nil {
+		t.Errorf("xlsx export is not a workbook: %v", err)
+	}
+	if cd := rr.Header().Get("Content-Disposition"); !strings.Contains(cd, ".xlsx") {
+		t.Errorf("Content-Disposition = %q", cd)
+	}
+
+	if rr := runExport(t, handleMetricsExport, "/api/v1/metrics/export?since=2020-01-01", admin); rr.Code != http.StatusBadRequest {
+		t.Errorf("oversized range: expected 400, got %d", rr.Code)
+	}
+}
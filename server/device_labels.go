@@ -0,0 +1,145 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	authz "printmaster/server/authz"
+	"printmaster/server/labels"
+	"printmaster/server/storage"
+)
+
+// maxLabelsPerRequest bounds a single label sheet download.
+const maxLabelsPerRequest = 1000
+
+// deviceLabelsRequest selects the devices and sheet layout for a label PDF.
+type deviceLabelsRequest struct {
+	Serials  []string `json:"serials"`
+	Template string   `json:"template"`
+	Skip     int      `json:"skip"`    // labels already used on the first sheet
+	Outline  bool     `json:"outline"` // draw label borders for test prints
+}
+
+// handleDeviceLabels renders asset labels for the selected devices as a PDF.
+// Each QR code links to the tech view for the device, so scanning a label
+// opens the device on a phone.
+func handleDeviceLabels(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !authorizeOrReject(w, r, authz.ActionDevicesRead, authz.ResourceRef{}) {
+		return
+	}
+	var req deviceLabelsRequest
+	if err := decodeJSONBody(r, &req); err != nil {
+		http.Error(w, "invalid JSON", http.StatusBadRequest)
+		return
+	}
+	if req.Template == "" {
+		req.Template = labels.DefaultTemplate
+	}
+	tmpl, ok := labels.LookupTemplate(req.Template)
+	if !ok {
+		http.Error(w, "unknown template: "+req.Template, http.StatusBadRequest)
+		return
+	}
+	if req.Skip < 0 || req.Skip >= tmpl.PerSheet {
+		http.Error(w, fmt.Sprintf("skip must be between 0 and %d", tmpl.PerSheet-1), http.StatusBadRequest)
+		return
+	}
+
+	var serials []string
+	seen := make(map[string]bool)
+	for _, s := range req.Serials {
+		s = strings.TrimSpace(s)
+		if s != "" && !seen[s] {
+			seen[s] = true
+			serials = append(serials, s)
+		}
+	}
+	if len(serials) == 0 {
+		http.Error(w, "serials required", http.StatusBadRequest)
+		return
+	}
+	if len(serials) > maxLabelsPerRequest {
+		http.Error(w, fmt.Sprintf("at most %d labels per request", maxLabelsPerRequest), http.StatusBadRequest)
+		return
+	}
+
+	base := labelBaseURL(r)
+	items := make([]labels.Label, 0, len(serials))
+	for _, serial := range serials {
+		device, _, ok := resolveNoteDevice(r.Context(), r, serial)
+		if !ok {
+			http.Error(w, "device not found: "+serial, http.StatusNotFound)
+			return
+		}
+		items = append(items, deviceLabel(device, base))
+	}
+
+	var buf bytes.Buffer
+	if err := labels.Render(&buf, tmpl, items, labels.Options{Skip: req.Skip, Outline: req.Outline}); err != nil {
+		logError("Failed to render device labels", "template", tmpl.ID, "count", len(items), "error", err)
+		http.Error(w, "failed to render labels", http.StatusInternalServerError)
+		return
+	}
+	logInfo("Rendered device labels", "template", tmpl.ID, "count", len(items))
+	w.Header().Set("Content-Type", "application/pdf")
+	w.Header().Set("Content-Disposition", `attachment; filename="device-labels.pdf"`)
+	w.Write(buf.Bytes())
+}
+
+// handleDeviceLabelTemplates lists the supported label sheets.
+func handleDeviceLabelTemplates(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !authorizeOrReject(w, r, authz.ActionDevicesRead, authz.ResourceRef{}) {
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"templates": labels.Templates(),
+		"default":   labels.DefaultTemplate,
+	})
+}
+
+// labelBaseURL is the address printed labels link to. The configured public
+// URL wins, since labels outlive the address a browser happened to use.
+func labelBaseURL(r *http.Request) string {
+	if serverConfig != nil && strings.TrimSpace(serverConfig.Server.PublicURL) != "" {
+		return strings.TrimRight(strings.TrimSpace(serverConfig.Server.PublicURL), "/")
+	}
+	scheme := "https"
+	if r.TLS == nil {
+		scheme = "http"
+	}
+	return scheme + "://" + r.Host
+}
+
+// deviceLabel builds the label for a device: the asset number as title
+// (falling back to the serial), then serial, model and location.
+func deviceLabel(device *storage.Device, base string) labels.Label {
+	l := labels.Label{
+		QR:    base + "/tech?code=" + url.QueryEscape(device.Serial),
+		Title: device.AssetNumber,
+	}
+	if l.Title == "" {
+		l.Title = device.Serial
+	} else {
+		l.Lines = append(l.Lines, "S/N "+device.Serial)
+	}
+	if model := strings.TrimSpace(device.Manufacturer + " " + device.Model); model != "" {
+		l.Lines = append(l.Lines, model)
+	}
+	if device.Location != "" {
+		l.Lines = append(l.Lines, device.Location)
+	}
+	return l
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"printmaster/server/storage"
+)
+
+func TestDeviceLabels(t *testing.T) {
+	store := SetupTestStore(t)
+	ctx := context.Background()
+	for _, a := range []*storage.Agent{
+		{AgentID: "agent-a", Hostname: "a", Token: "ta", TenantID: "tenant-a", Status: "active", RegisteredAt: time.Now(), LastSeen: time.Now()},
+		{AgentID: "agent-b", Hostname: "b", Token: "tb", TenantID: "tenant-b", Status: "active", RegisteredAt: time.Now(), LastSeen: time.Now()},
+	} {
+		if err := store.RegisterAgent(ctx, a); err != nil {
+			t.Fatalf("RegisterAgent: %v", err)
+		}
+	}
+	for serial, agentID := range map[string]string{"SN-A": "agent-a", "SN-B": "agent-b"} {
+		dev := &storage.Device{}
+		dev.Serial, dev.AgentID, dev.LastSeen = serial, agentID, time.Now()
+		dev.AssetNumber, dev.Model = "PM-"+serial, "LaserJet M404"
+		if err := store.UpsertDevice(ctx, dev); err != nil {
+			t.Fatalf("UpsertDevice: %v", err)
+		}
+	}
+
+	call := func(user *storage.User, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/devices/labels", strings.NewReader(body))
+		rr := httptest.NewRecorder()
+		handleDeviceLabels(rr, InjectTestUser(req, user))
+		return rr
+	}
+	viewer := NewTestUser(storage.RoleViewer, "tenant-a")
+
+	rr := call(viewer, `{"serials":["SN-A"],"template":"avery-l7160"}`)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("render: expected 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	if ct := rr.Header().Get("Content-Type"); ct != "application/pdf" {
+		t.Errorf("Content-Type = %q", ct)
+	}
+	if !bytes.HasPrefix(rr.Body.Bytes(), []byte("%PDF-")) || !bytes.Contains(rr.Body.Bytes(), []byte("(PM-SN-A) Tj")) {
+		t.Errorf("PDF missing the asset number")
+	}
+
+	if rr := call(viewer, `{"serials":["SN-A","SN-B"]}`); rr.Code != http.StatusNotFound {
+		t.Errorf("other tenant's device: expected 404, got %d", rr.Code)
+	}
+	if rr := call(viewer, `{"serials":["SN-A"],"template":"avery-9999"}`); rr.Code != http.StatusBadRequest {
+		t.Errorf("unknown template: expected 400, got %d", rr.Code)
+	}
+	if rr := call(viewer, `{"serials":["SN-A"],"skip":30}`); rr.Code != http.StatusBadRequest {
+		t.Errorf("skip past the sheet: expected 400, got %d", rr.Code)
+	}
+	if rr := call(viewer, `{"serials":[]}`); rr.Code != http.StatusBadRequest {
+		t.Errorf("no serials: expected 400, got %d", rr.Code)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/devices/labels/templates", nil)
+	tr := httptest.NewRecorder()
+	handleDeviceLabelTemplates(tr, InjectTestUser(req, viewer))
+	if tr.Code != http.StatusOK || !strings.Contains(tr.Body.String(), `"avery-5160"`) {
+		t.Errorf("templates: got %d %s", tr.Code, tr.Body.String())
+	}
+}
+
+func TestDeviceLabelContent(t *testing.T) {
+	dev := &storage.Device{}
+	dev.Serial, dev.Manufacturer, dev.Model, dev.Location = "SN 1", "HP", "M404", "Room 2"
+	l := deviceLabel(dev, "https://pm.example.com")
+	if l.QR != "https://pm.example.com/tech?code=SN+1" {
+		t.Errorf("QR = %q", l.QR)
+	}
+	if l.Title != "SN 1" || len(l.Lines) != 2 || l.Lines[0] != "HP M404" {
+		t.Errorf("label without asset number = %+v", l)
+	}
+	dev.AssetNumber = "A-7"
+	if l := deviceLabel(dev, ""); l.Title != "A-7" || l.Lines[0] != "S/N SN 1" {
+		t.Errorf("label with asset number = %+v", l)
+	}
+}
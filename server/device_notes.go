@@ -0,0 +1,370 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	authz "printmaster/server/authz"
+	"printmaster/server/storage"
+)
+
+// deviceNoteRequest creates or updates a device note. OccurredAt defaults to
+// now on create and to the stored value on update.
+type deviceNoteRequest struct {
+	Serial       string     `json:"serial"`
+	Kind         string     `json:"kind"`
+	Body         string     `json:"body"`
+	Part         string     `json:"part"`
+	Vendor       string     `json:"vendor"`
+	FirmwareFrom string     `json:"firmware_from"`
+	FirmwareTo   string     `json:"firmware_to"`
+	PageCount    *int64     `json:"page_count,omitempty"`
+	OccurredAt   *time.Time `json:"occurred_at,omitempty"`
+}
+
+// TimelineEntry is one event in a device's timeline.
+type TimelineEntry struct {
+	Time     time.Time `json:"time"`
+	Source   string    `json:"source"` // note, alert
+	Kind     string    `json:"kind"`   // note kind or alert type
+	ID       int64     `json:"id"`
+	Summary  string    `json:"summary"`
+	Author   string    `json:"author,omitempty"`
+	Severity string    `json:"severity,omitempty"`
+	Status   string    `json:"status,omitempty"`
+}
+
+// resolveNoteDevice looks up a device and the tenant of the agent reporting
+// it. Devices outside the caller's tenants are reported as not found.
+func resolveNoteDevice(ctx context.Context, r *http.Request, serial string) (*storage.Device, string, bool) {
+	device, err := serverStore.GetDevice(ctx, serial)
+	if err != nil || device == nil {
+		return nil, "", false
+	}
+	tenantID := ""
+	if device.AgentID != "" {
+		if agent, err := serverStore.GetAgent(ctx, device.AgentID); err == nil && agent != nil {
+			tenantID = agent.TenantID
+		}
+	}
+	scope, ok := tenantScope(getPrincipal(r))
+	if !ok || !tenantAllowed(scope, tenantID) {
+		return nil, "", false
+	}
+	return device, tenantID, true
+}
+
+// handleDeviceNotes lists notes (GET ?serial=&kind=&since=&until=&limit=) or
+// adds one to a device (POST).
+func handleDeviceNotes(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	switch r.Method {
+	case http.MethodGet:
+		if !authorizeOrReject(w, r, authz.ActionDeviceNotesRead, authz.ResourceRef{}) {
+			return
+		}
+		q := r.URL.Query()
+		filter := storage.DeviceNoteFilter{Limit: 200}
+		if serial := strings.TrimSpace(q.Get("serial")); serial != "" {
+			if _, _, ok := resolveNoteDevice(ctx, r, serial); !ok {
+				http.Error(w, "device not found", http.StatusNotFound)
+				return
+			}
+			filter.Serial = serial
+		}
+		principal := getPrincipal(r)
+		if principal != nil && !principal.IsAdmin() {
+			filter.TenantIDs = principal.AllowedTenantIDs()
+			if len(filter.TenantIDs) == 0 {
+				writeDeviceNotes(w, nil)
+				return
+			}
+		}
+		if kinds := strings.TrimSpace(q.Get("kind")); kinds != "" {
+			filter.Kinds = strings.Split(kinds, ",")
+		}
+		var err error
+		if filter.Since, err = parseOptionalRFC3339(q.Get("since")); err != nil {
+			http.Error(w, "invalid since", http.StatusBadRequest)
+			return
+		}
+		if filter.Until, err = parseOptionalRFC3339(q.Get("until")); err != nil {
+			http.Error(w, "invalid until", http.StatusBadRequest)
+			return
+		}
+		if v := strings.TrimSpace(q.Get("limit")); v != "" {
+			if filter.Limit, err = strconv.Atoi(v); err != nil || filter.Limit <= 0 {
+				http.Error(w, "invalid limit", http.StatusBadRequest)
+				return
+			}
+		}
+		notes, err := serverStore.ListDeviceNotes(ctx, filter)
+		if err != nil {
+			logError("Failed to list device notes", "error", err)
+			http.Error(w, "failed to list device notes", http.StatusInternalServerError)
+			return
+		}
+		writeDeviceNotes(w, notes)
+	case http.MethodPost:
+		var req deviceNoteRequest
+		if err := decodeJSONBody(r, &req); err != nil {
+			http.Error(w, "invalid JSON", http.StatusBadRequest)
+			return
+		}
+		req.Serial = strings.TrimSpace(req.Serial)
+		if req.Serial == "" {
+			http.Error(w, "serial is required", http.StatusBadRequest)
+			return
+		}
+		device, tenantID, ok := resolveNoteDevice(ctx, r, req.Serial)
+		if !ok {
+			http.Error(w, "device not found", http.StatusNotFound)
+			return
+		}
+		if !authorizeOrReject(w, r, authz.ActionDeviceNotesWrite, authz.ResourceRef{TenantIDs: []string{tenantID}}) {
+			return
+		}
+		_, _, actorName, _ := auditActorFromPrincipal(r)
+		note := &storage.DeviceNote{Serial: device.Serial, TenantID: tenantID, Author: actorName}
+		req.applyTo(note)
+		if err := note.Validate(); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err := serverStore.AddDeviceNote(ctx, note); err != nil {
+			logError("Failed to add device note", "serial", note.Serial, "error", err)
+			http.Error(w, "failed to add device note", http.StatusInternalServerError)
+			return
+		}
+		auditDeviceNote(r, "device_note.create", note)
+		broadcastDeviceNote("created", note)
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(note)
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// handleDeviceNote reads, updates or deletes one note. Only the author or an
+// admin may change a note.
+func handleDeviceNote(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	id, err := strconv.ParseInt(strings.TrimPrefix(r.URL.Path, "/api/v1/device-notes/"), 10, 64)
+	if err != nil {
+		http.Error(w, "invalid note id", http.StatusBadRequest)
+		return
+	}
+	note, err := serverStore.GetDeviceNote(ctx, id)
+	if err != nil {
+		logError("Failed to load device note", "id", id, "error", err)
+		http.Error(w, "failed to load device note", http.StatusInternalServerError)
+		return
+	}
+	scope, ok := tenantScope(getPrincipal(r))
+	if note == nil || !ok || !tenantAllowed(scope, note.TenantID) {
+		http.Error(w, "device note not found", http.StatusNotFound)
+		return
+	}
+	resource := authz.ResourceRef{TenantIDs: []string{note.TenantID}}
+
+	if r.Method == http.MethodGet {
+		if !authorizeOrReject(w, r, authz.ActionDeviceNotesRead, resource) {
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(note)
+		return
+	}
+	if r.Method != http.MethodPut && r.Method != http.MethodDelete {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !authorizeOrReject(w, r, authz.ActionDeviceNotesWrite, resource) {
+		return
+	}
+	_, _, actorName, _ := auditActorFromPrincipal(r)
+	if principal := getPrincipal(r); principal == nil || (!principal.IsAdmin() && note.Author != actorName) {
+		http.Error(w, "only the author or an admin can change this note", http.StatusForbidden)
+		return
+	}
+
+	if r.Method == http.MethodDelete {
+		if err := serverStore.DeleteDeviceNote(ctx, id); err != nil {
+			logError("Failed to delete device note", "id", id, "error", err)
+			http.Error(w, "failed to delete device note", http.StatusInternalServerError)
+			return
+		}
+		auditDeviceNote(r, "device_note.delete", note)
+		broadcastDeviceNote("deleted", note)
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	var req deviceNoteRequest
+	if err := decodeJSONBody(r, &req); err != nil {
+		http.Error(w, "invalid JSON", http.StatusBadRequest)
+		return
+	}
+	req.applyTo(note)
+	if err := note.Validate(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := serverStore.UpdateDeviceNote(ctx, note); err != nil {
+		logError("Failed to update device note", "id", id, "error", err)
+		http.Error(w, "failed to update device note", http.StatusInternalServerError)
+		return
+	}
+	auditDeviceNote(r, "device_note.update", note)
+	broadcastDeviceNote("updated", note)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(note)
+}
+
+// applyTo copies the editable fields onto note. The serial is fixed once the
+// note exists.
+func (req deviceNoteRequest) applyTo(note *storage.DeviceNote) {
+	note.Kind = req.Kind
+	note.Body = req.Body
+	note.Part = req.Part
+	note.Vendor = req.Vendor
+	note.FirmwareFrom = req.FirmwareFrom
+	note.FirmwareTo = req.FirmwareTo
+	note.PageCount = req.PageCount
+	if req.OccurredAt != nil {
+		note.OccurredAt = req.OccurredAt.UTC()
+	}
+}
+
+// handleDeviceTimeline merges a device's notes, maintenance entries and
+// alerts into one list, newest first.
+// GET /api/v1/devices/timeline?serial=...&since=...&limit=...
+func handleDeviceTimeline(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "GET only", http.StatusMethodNotAllowed)
+		return
+	}
+	ctx := r.Context()
+	q := r.URL.Query()
+	serial := strings.TrimSpace(q.Get("serial"))
+	if serial == "" {
+		http.Error(w, "serial parameter required", http.StatusBadRequest)
+		return
+	}
+	device, tenantID, ok := resolveNoteDevice(ctx, r, serial)
+	if !ok {
+		http.Error(w, "device not found", http.StatusNotFound)
+		return
+	}
+	if !authorizeOrReject(w, r, authz.ActionDevicesRead, authz.ResourceRef{TenantIDs: []string{tenantID}}) {
+		return
+	}
+	since, err := parseOptionalRFC3339(q.Get("since"))
+	if err != nil {
+		http.Error(w, "invalid since", http.StatusBadRequest)
+		return
+	}
+	limit := 200
+	if v := strings.TrimSpace(q.Get("limit")); v != "" {
+		if limit, err = strconv.Atoi(v); err != nil || limit <= 0 {
+			http.Error(w, "invalid limit", http.StatusBadRequest)
+			return
+		}
+	}
+
+	entries := []TimelineEntry{}
+	notes, err := serverStore.ListDeviceNotes(ctx, storage.DeviceNoteFilter{Serial: device.Serial, Since: since, Limit: limit})
+	if err != nil {
+		logError("Failed to list device notes for timeline", "serial", serial, "error", err)
+		http.Error(w, "failed to load timeline", http.StatusInternalServerError)
+		return
+	}
+	for _, n := range notes {
+		entries = append(entries, TimelineEntry{
+			Time: n.OccurredAt, Source: "note", Kind: n.Kind, ID: n.ID, Summary: n.Summary(), Author: n.Author,
+		})
+	}
+
+	if device.AgentID != "" {
+		filter := storage.AlertFilters{AgentID: device.AgentID}
+		if !since.IsZero() {
+			filter.StartTime = &since
+		}
+		alerts, err := serverStore.ListAlerts(ctx, filter)
+		if err != nil {
+			logWarn("Failed to list alerts for timeline", "serial", serial, "error", err)
+		}
+		for _, a := range alerts {
+			if a == nil || a.DeviceSerial != device.Serial {
+				continue
+			}
+			entries = append(entries, TimelineEntry{
+				Time: a.TriggeredAt, Source: "alert", Kind: a.Type, ID: a.ID, Summary: a.Title,
+				Severity: a.Severity, Status: a.Status,
+			})
+		}
+	}
+
+	sort.SliceStable(entries, func(i, j int) bool { return entries[i].Time.After(entries[j].Time) })
+	if len(entries) > limit {
+		entries = entries[:limit]
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"serial":  device.Serial,
+		"entries": entries,
+	})
+}
+
+func parseOptionalRFC3339(v string) (time.Time, error) {
+	v = strings.TrimSpace(v)
+	if v == "" {
+		return time.Time{}, nil
+	}
+	return time.Parse(time.RFC3339, v)
+}
+
+func writeDeviceNotes(w http.ResponseWriter, notes []*storage.DeviceNote) {
+	if notes == nil {
+		notes = []*storage.DeviceNote{}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(notes)
+}
+
+func auditDeviceNote(r *http.Request, action string, note *storage.DeviceNote) {
+	actorType, actorID, actorName, actorTenant := auditActorFromPrincipal(r)
+	logInfo("Device note change", "action", action, "id", note.ID, "serial", note.Serial, "actor", actorName)
+	logAuditEntry(r.Context(), &storage.AuditEntry{
+		ActorType:  actorType,
+		ActorID:    actorID,
+		ActorName:  actorName,
+		TenantID:   actorTenant,
+		Action:     action,
+		TargetType: "device",
+		TargetID:   note.Serial,
+		Details:    fmt.Sprintf("note=%d kind=%s", note.ID, note.Kind),
+		IPAddress:  extractClientIP(r),
+		UserAgent:  r.Header.Get("User-Agent"),
+	})
+}
+
+func broadcastDeviceNote(change string, note *storage.DeviceNote) {
+	sseHub.Broadcast(SSEEvent{
+		Type: "device_note",
+		Data: map[string]interface{}{
+			"change":    change,
+			"id":        note.ID,
+			"serial":    note.Serial,
+			"tenant_id": note.TenantID,
+			"kind":      note.Kind,
+		},
+	})
+}
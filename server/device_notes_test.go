@@ -0,0 +1,109 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"printmaster/server/storage"
+)
+
+func TestDeviceNotesAndTimeline(t *testing.T) {
+	store := SetupTestStore(t)
+	ctx := context.Background()
+	for _, a := range []*storage.Agent{
+		{AgentID: "agent-a", Hostname: "a", Token: "ta", TenantID: "tenant-a", Status: "active", RegisteredAt: time.Now(), LastSeen: time.Now()},
+		{AgentID: "agent-b", Hostname: "b", Token: "tb", TenantID: "tenant-b", Status: "active", RegisteredAt: time.Now(), LastSeen: time.Now()},
+	} {
+		if err := store.RegisterAgent(ctx, a); err != nil {
+			t.Fatalf("RegisterAgent: %v", err)
+		}
+	}
+	for serial, agentID := range map[string]string{"SN-A": "agent-a", "SN-B": "agent-b"} {
+		dev := &storage.Device{}
+		dev.Serial, dev.AgentID, dev.LastSeen = serial, agentID, time.Now()
+		if err := store.UpsertDevice(ctx, dev); err != nil {
+			t.Fatalf("UpsertDevice: %v", err)
+		}
+	}
+	if _, err := store.CreateAlert(ctx, &storage.Alert{
+		Type: "device_offline", Severity: "warning", Scope: "device", Status: "active",
+		AgentID: "agent-a", DeviceSerial: "SN-A", Title: "Printer offline", TriggeredAt: time.Now().Add(-2 * time.Hour),
+	}); err != nil {
+		t.Fatalf("CreateAlert: %v", err)
+	}
+
+	call := func(user *storage.User, handler http.HandlerFunc, method, path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		rr := httptest.NewRecorder()
+		handler(rr, InjectTestUser(req, user))
+		return rr
+	}
+	operator := NewTestUser(storage.RoleOperator, "tenant-a")
+	viewer := NewTestUser(storage.RoleViewer, "tenant-a")
+
+	if rr := call(viewer, handleDeviceNotes, http.MethodPost, "/api/v1/device-notes", `{"serial":"SN-A","body":"x"}`); rr.Code != http.StatusForbidden {
+		t.Fatalf("viewer create: expected 403, got %d", rr.Code)
+	}
+	if rr := call(operator, handleDeviceNotes, http.MethodPost, "/api/v1/device-notes", `{"serial":"SN-B","body":"x"}`); rr.Code != http.StatusNotFound {
+		t.Fatalf("other tenant's device: expected 404, got %d", rr.Code)
+	}
+	if rr := call(operator, handleDeviceNotes, http.MethodPost, "/api/v1/device-notes", `{"serial":"SN-A","kind":"part_replaced"}`); rr.Code != http.StatusBadRequest {
+		t.Fatalf("missing part: expected 400, got %d", rr.Code)
+	}
+
+	rr := call(operator, handleDeviceNotes, http.MethodPost, "/api/v1/device-notes",
+		`{"serial":"SN-A","kind":"part_replaced","part":"Pickup roller","body":"Tray 2 misfeeds","page_count":81234}`)
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("create: %d %s", rr.Code, rr.Body.String())
+	}
+	var note storage.DeviceNote
+	if err := json.Unmarshal(rr.Body.Bytes(), &note); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if note.TenantID != "tenant-a" || note.Author != operator.Username {
+		t.Fatalf("unexpected note: %+v", note)
+	}
+
+	rr = call(viewer, handleDeviceNotes, http.MethodGet, "/api/v1/device-notes?serial=SN-A", "")
+	var notes []storage.DeviceNote
+	if err := json.Unmarshal(rr.Body.Bytes(), &notes); err != nil || len(notes) != 1 {
+		t.Fatalf("viewer list: %d %s", rr.Code, rr.Body.String())
+	}
+
+	rr = call(viewer, handleDeviceTimeline, http.MethodGet, "/api/v1/devices/timeline?serial=SN-A", "")
+	var timeline struct {
+		Entries []TimelineEntry `json:"entries"`
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &timeline); err != nil || len(timeline.Entries) != 2 {
+		t.Fatalf("timeline: %d %s", rr.Code, rr.Body.String())
+	}
+	if e := timeline.Entries[0]; e.Source != "note" || e.Summary != "Replaced Pickup roller: Tray 2 misfeeds" {
+		t.Fatalf("expected newest entry to be the note: %+v", e)
+	}
+	if e := timeline.Entries[1]; e.Source != "alert" || e.Summary != "Printer offline" {
+		t.Fatalf("expected alert entry: %+v", e)
+	}
+
+	path := "/api/v1/device-notes/" + strconv.FormatInt(note.ID, 10)
+	otherOperator := NewTestUser(storage.RoleOperator, "tenant-a")
+	otherOperator.Username = "someone-else"
+	if rr := call(otherOperator, handleDeviceNote, http.MethodDelete, path, ""); rr.Code != http.StatusForbidden {
+		t.Fatalf("non-author delete: expected 403, got %d", rr.Code)
+	}
+	rr = call(operator, handleDeviceNote, http.MethodPut, path, `{"kind":"service_visit","vendor":"Acme Copiers"}`)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("update: %d %s", rr.Code, rr.Body.String())
+	}
+	if got, _ := store.GetDeviceNote(ctx, note.ID); got == nil || got.Kind != storage.DeviceNoteKindServiceVisit || got.Part != "" {
+		t.Fatalf("update not applied: %+v", got)
+	}
+	if rr := call(NewTestUser(storage.RoleAdmin), handleDeviceNote, http.MethodDelete, path, ""); rr.Code != http.StatusNoContent {
+		t.Fatalf("admin delete: expected 204, got %d", rr.Code)
+	}
+}
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	authz "printmaster/server/authz"
+	"printmaster/server/devicestatus"
+	"printmaster/server/storage"
+)
+
+// loadDeviceStatusRules installs admin-defined status rules from the database.
+// Invalid stored rules are logged and the built-in defaults stay active.
+func loadDeviceStatusRules(ctx context.Context, store storage.Store) {
+	rules, err := store.ListDeviceStatusRules(ctx)
+	if err != nil {
+		logWarn("Failed to load device status rules", "error", err)
+		return
+	}
+	if err := devicestatus.SetCustomRules(rules); err != nil {
+		logWarn("Stored device status rules are invalid; using defaults", "error", err)
+		return
+	}
+	if len(rules) > 0 {
+		logInfo("Device status rules loaded", "custom_rules", len(rules))
+	}
+}
+
+// deviceStatusState returns the canonical state for a device.
+func deviceStatusState(d *storage.Device) devicestatus.State {
+	if d == nil {
+		return devicestatus.StateReady
+	}
+	return devicestatus.Active().Classify(d.Manufacturer, d.StatusMessages).State
+}
+
+// handleDeviceStatusRules returns (GET) or replaces (PUT) the admin-defined
+// status normalization rules. Built-in defaults are returned for reference.
+func handleDeviceStatusRules(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	switch r.Method {
+	case http.MethodGet:
+		if !authorizeOrReject(w, r, authz.ActionSettingsServerRead, authz.ResourceRef{}) {
+			return
+		}
+		rules, err := serverStore.ListDeviceStatusRules(ctx)
+		if err != nil {
+			logError("Failed to list device status rules", "error", err)
+			http.Error(w, "failed to list device status rules", http.StatusInternalServerError)
+			return
+		}
+		if rules == nil {
+			rules = []devicestatus.Rule{}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"rules":    rules,
+			"defaults": devicestatus.DefaultRules(),
+			"states":   devicestatus.States,
+		})
+	case http.MethodPut:
+		if !authorizeOrReject(w, r, authz.ActionSettingsServerWrite, authz.ResourceRef{}) {
+			return
+		}
+		var req struct {
+			Rules []devicestatus.Rule `json:"rules"`
+		}
+		if err := decodeJSONBody(r, &req); err != nil {
+			http.Error(w, "invalid JSON", http.StatusBadRequest)
+			return
+		}
+		for i := range req.Rules {
+			req.Rules[i].ID = 0
+			req.Rules[i].Vendor = strings.TrimSpace(req.Rules[i].Vendor)
+			req.Rules[i].State = devicestatus.State(strings.ToLower(strings.TrimSpace(string(req.Rules[i].State))))
+		}
+		// Validate before persisting so a bad regex never reaches the database
+		if _, err := devicestatus.New(req.Rules); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err := serverStore.ReplaceDeviceStatusRules(ctx, req.Rules); err != nil {
+			logError("Failed to save device status rules", "error", err)
+			http.Error(w, "failed to save device status rules", http.StatusInternalServerError)
+			return
+		}
+		if err := devicestatus.SetCustomRules(req.Rules); err != nil {
+			logError("Failed to apply device status rules", "error", err)
+			http.Error(w, "failed to apply device status rules", http.StatusInternalServerError)
+			return
+		}
+
+		actor := ""
+		if principal := getPrincipal(r); principal != nil && principal.User != nil {
+			actor = principal.User.Username
+		}
+		logInfo("Device status rules updated", "custom_rules", len(req.Rules), "updated_by", actor)
+		logAuditEntry(ctx, &storage.AuditEntry{
+			ActorType:  storage.AuditActorUser,
+			ActorID:    actor,
+			ActorName:  actor,
+			Action:     "settings.device_status_rules.update",
+			TargetType: "device_status_rules",
+			Details:    fmt.Sprintf("Replaced device status rules (%d custom rules)", len(req.Rules)),
+			IPAddress:  extractClientIP(r),
+			UserAgent:  r.Header.Get("User-Agent"),
+		})
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": true,
+			"rules":   req.Rules,
+		})
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// handleDeviceStatusClassify previews how the active rules classify a set of
+// status messages (POST {vendor, messages}).
+func handleDeviceStatusClassify(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "POST only", http.StatusMethodNotAllowed)
+		return
+	}
+	if !authorizeOrReject(w, r, authz.ActionSettingsServerRead, authz.ResourceRef{}) {
+		return
+	}
+	var req struct {
+		Vendor   string   `json:"vendor"`
+		Messages []string `json:"messages"`
+	}
+	if err := decodeJSONBody(r, &req); err != nil {
+		http.Error(w, "invalid JSON", http.StatusBadRequest)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(devicestatus.Active().Classify(req.Vendor, req.Messages))
+}
@@ -0,0 +1,23 @@
+package devicestatus
+
+// DefaultRules returns the built-in rules. Custom rules are evaluated first, so
+// admins can override any of these.
+func DefaultRules() []Rule {
+	return []Rule{
+		// Vendor-specific wording and error code formats
+		{Vendor: "hp", Pattern: `\b(?:49|79)\.[0-9a-f]{2,4}\b|\b1[03]\.\d{2}\.\d{2}\b`, State: StateError, Description: "HP numeric error codes"},
+		{Vendor: "canon", Pattern: `\bE\d{3}-\d{4}\b|service call`, State: StateError, Description: "Canon service call codes"},
+		{Vendor: "xerox", Pattern: `fault code|\b\d{3}-\d{3}\b`, State: StateError, Description: "Xerox fault codes"},
+		{Vendor: "ricoh", Pattern: `energy saver`, State: StateReady, Description: "Ricoh energy saver mode"},
+		{Vendor: "kyocera", Pattern: `add paper|load paper`, State: StateWarning, Description: "Kyocera paper prompts"},
+		{Vendor: "brother", Pattern: `deep sleep`, State: StateReady, Description: "Brother deep sleep mode"},
+
+		// Generic rules, most specific first
+		{Pattern: `sleep|power ?sav|low power|energy sav`, State: StateReady, Description: "Power saving modes"},
+		{Pattern: `offline|not connected|unreachable|no response`, State: StateOffline, Description: "Device offline"},
+		{Pattern: `jam|error|fault|fail|service call|call service|(?:door|cover) open`, State: StateError, Description: "Errors and jams"},
+		{Pattern: `maintenance|calibrat|cleaning|service mode|firmware update|upgrading|warming up`, State: StateMaintenance, Description: "Maintenance in progress"},
+		{Pattern: `warn|\blow\b|near end|empty|replace|out of`, State: StateWarning, Description: "Supply and attention warnings"},
+		{Pattern: `ready|idle|online|printing|processing`, State: StateReady, Description: "Normal operation"},
+	}
+}
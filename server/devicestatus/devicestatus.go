@@ -0,0 +1,209 @@
+// Package devicestatus normalizes the free-form status messages printers report
+// into a small set of canonical states. Vendors word the same condition very
+// differently ("Toner Low", "Replace toner soon", "E000-0001"), so dashboards,
+// reports and alerting classify devices through a shared rule set instead of
+// ad-hoc substring checks.
+package devicestatus
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"sync/atomic"
+)
+
+// State is a canonical device state.
+type State string
+
+const (
+	StateReady       State = "ready"
+	StateMaintenance State = "maintenance"
+	StateWarning     State = "warning"
+	StateError       State = "error"
+	StateOffline     State = "offline"
+)
+
+// States lists the canonical states in ascending severity.
+var States = []State{StateReady, StateMaintenance, StateWarning, StateError, StateOffline}
+
+// Severity orders states so the worst condition of a device can be picked.
+func (s State) Severity() int {
+	for i, st := range States {
+		if st == s {
+			return i
+		}
+	}
+	return 0
+}
+
+// ParseState converts a string to a canonical State.
+func ParseState(s string) (State, bool) {
+	st := State(strings.ToLower(strings.TrimSpace(s)))
+	for _, known := range States {
+		if st == known {
+			return st, true
+		}
+	}
+	return "", false
+}
+
+// Rule maps status messages matching Pattern to State. Vendor restricts the
+// rule to devices whose manufacturer contains it (case-insensitive).
+type Rule struct {
+	ID          int64  `json:"id,omitempty"`
+	Vendor      string `json:"vendor,omitempty"`
+	Pattern     string `json:"pattern"`
+	State       State  `json:"state"`
+	Description string `json:"description,omitempty"`
+}
+
+// Result is the normalized state of a device.
+type Result struct {
+	State   State  `json:"state"`
+	Message string `json:"message,omitempty"` // Status message that determined State
+	Rule    *Rule  `json:"rule,omitempty"`    // Rule that matched Message
+}
+
+type compiledRule struct {
+	rule   Rule
+	vendor string
+	re     *regexp.Regexp
+}
+
+// Normalizer classifies status messages using an ordered rule set.
+type Normalizer struct {
+	rules []compiledRule
+}
+
+// New compiles rules into a Normalizer. For each message vendor-specific rules
+// are tried before generic ones; within each group the first match wins.
+func New(rules []Rule) (*Normalizer, error) {
+	return newTiered(rules)
+}
+
+// newTiered compiles several rule sets so that every rule of an earlier tier
+// is evaluated before any rule of a later one.
+func newTiered(tiers ...[]Rule) (*Normalizer, error) {
+	n := &Normalizer{}
+	offset := 0
+	for _, rules := range tiers {
+		var vendorRules, genericRules []compiledRule
+		for i, r := range rules {
+			if _, ok := ParseState(string(r.State)); !ok {
+				return nil, fmt.Errorf("rule %d: invalid state %q", offset+i+1, r.State)
+			}
+			if strings.TrimSpace(r.Pattern) == "" {
+				return nil, fmt.Errorf("rule %d: pattern is required", offset+i+1)
+			}
+			re, err := regexp.Compile("(?i)" + r.Pattern)
+			if err != nil {
+				return nil, fmt.Errorf("rule %d: invalid pattern: %w", offset+i+1, err)
+			}
+			cr := compiledRule{rule: r, vendor: strings.ToLower(strings.TrimSpace(r.Vendor)), re: re}
+			if cr.vendor != "" {
+				vendorRules = append(vendorRules, cr)
+			} else {
+				genericRules = append(genericRules, cr)
+			}
+		}
+		n.rules = append(n.rules, vendorRules...)
+		n.rules = append(n.rules, genericRules...)
+		offset += len(rules)
+	}
+	return n, nil
+}
+
+// ClassifyMessage returns the state for a single status message. ok is false
+// when no rule matched.
+func (n *Normalizer) ClassifyMessage(vendor, message string) (state State, rule *Rule, ok bool) {
+	if n == nil || strings.TrimSpace(message) == "" {
+		return "", nil, false
+	}
+	vendor = strings.ToLower(vendor)
+	for i := range n.rules {
+		cr := &n.rules[i]
+		if cr.vendor != "" && !strings.Contains(vendor, cr.vendor) {
+			continue
+		}
+		if cr.re.MatchString(message) {
+			return cr.rule.State, &cr.rule, true
+		}
+	}
+	return "", nil, false
+}
+
+// Classify returns the most severe state across all messages. Devices with no
+// recognized messages are ready.
+func (n *Normalizer) Classify(vendor string, messages []string) Result {
+	res := Result{State: StateReady}
+	for _, msg := range messages {
+		state, rule, ok := n.ClassifyMessage(vendor, msg)
+		if !ok {
+			continue
+		}
+		if res.Rule == nil || state.Severity() > res.State.Severity() {
+			res = Result{State: state, Message: msg, Rule: rule}
+		}
+	}
+	return res
+}
+
+// Flags classifies each message independently and reports which severities
+// are present. Fleet counters use it to tally error, warning and jam devices.
+func (n *Normalizer) Flags(vendor string, messages []string) (hasError, hasWarning, hasJam bool) {
+	for _, msg := range messages {
+		state, _, ok := n.ClassifyMessage(vendor, msg)
+		if !ok {
+			continue
+		}
+		switch state {
+		case StateError, StateOffline:
+			hasError = true
+		case StateWarning:
+			hasWarning = true
+		}
+	}
+	hasJam = HasJam(messages)
+	if hasJam {
+		hasError = true
+	}
+	return hasError, hasWarning, hasJam
+}
+
+var jamPattern = regexp.MustCompile(`(?i)\bjam`)
+
+// HasJam reports whether any message describes a paper jam. Jams are tracked
+// separately from the canonical state for dashboard counters.
+func HasJam(messages []string) bool {
+	for _, msg := range messages {
+		if jamPattern.MatchString(msg) {
+			return true
+		}
+	}
+	return false
+}
+
+var active atomic.Pointer[Normalizer]
+
+func init() {
+	n, err := New(DefaultRules())
+	if err != nil {
+		panic(err)
+	}
+	active.Store(n)
+}
+
+// Active returns the normalizer currently used server-wide.
+func Active() *Normalizer {
+	return active.Load()
+}
+
+// SetCustomRules installs admin-defined rules ahead of the built-in defaults.
+func SetCustomRules(custom []Rule) error {
+	n, err := newTiered(custom, DefaultRules())
+	if err != nil {
+		return err
+	}
+	active.Store(n)
+	return nil
+}
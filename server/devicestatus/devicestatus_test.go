@@ -0,0 +1,89 @@
+package devicestatus
+
+import "testing"
+
+func TestDefaultRulesClassify(t *testing.T) {
+	t.Parallel()
+
+	n, err := New(DefaultRules())
+	if err != nil {
+		t.Fatalf("New(DefaultRules()) error = %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		vendor   string
+		messages []string
+		want     State
+	}{
+		{"no messages", "HP", nil, StateReady},
+		{"ready", "Brother", []string{"Ready"}, StateReady},
+		{"sleep", "Canon", []string{"Sleep mode"}, StateReady},
+		{"toner low", "Brother", []string{"Ready", "Toner Low"}, StateWarning},
+		{"lower tray is not low", "Brother", []string{"Lower tray ready"}, StateReady},
+		{"worst wins", "HP", []string{"Toner Low", "Paper Jam in Tray 2"}, StateError},
+		{"offline", "Epson", []string{"Printer offline"}, StateOffline},
+		{"maintenance", "Epson", []string{"Cleaning print head"}, StateMaintenance},
+		{"hp error code", "HP Inc.", []string{"49.4C02"}, StateError},
+		{"canon code", "Canon", []string{"E000-0001"}, StateError},
+		{"vendor rule scoped", "Brother", []string{"E000-0001"}, StateReady},
+		{"ricoh energy saver", "RICOH", []string{"Energy Saver Mode"}, StateReady},
+		{"unknown message", "HP", []string{"Tray 1: Letter"}, StateReady},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			if got := n.Classify(tt.vendor, tt.messages); got.State != tt.want {
+				t.Fatalf("Classify(%q, %v) = %q (%q), want %q", tt.vendor, tt.messages, got.State, got.Message, tt.want)
+			}
+		})
+	}
+}
+
+func TestCustomRulesTakePrecedence(t *testing.T) {
+	custom := []Rule{
+		{Vendor: "acme", Pattern: `toner low`, State: StateMaintenance},
+		{Pattern: `49\.4C02`, State: StateWarning},
+	}
+	if err := SetCustomRules(custom); err != nil {
+		t.Fatalf("SetCustomRules() error = %v", err)
+	}
+	defer SetCustomRules(nil)
+
+	if got := Active().Classify("ACME Corp", []string{"Toner Low"}); got.State != StateMaintenance || got.Rule == nil || got.Rule.Vendor != "acme" {
+		t.Fatalf("custom rule not applied: %+v", got)
+	}
+	if got := Active().Classify("HP", []string{"Toner Low"}); got.State != StateWarning {
+		t.Fatalf("custom vendor rule leaked to other vendors: %+v", got)
+	}
+	// Generic custom rules still beat built-in vendor rules.
+	if got := Active().Classify("HP", []string{"49.4C02"}); got.State != StateWarning {
+		t.Fatalf("custom generic rule should override built-in vendor rule: %+v", got)
+	}
+}
+
+func TestNewValidatesRules(t *testing.T) {
+	t.Parallel()
+
+	if _, err := New([]Rule{{Pattern: "x", State: "broken"}}); err == nil {
+		t.Fatal("expected error for invalid state")
+	}
+	if _, err := New([]Rule{{Pattern: "(", State: StateError}}); err == nil {
+		t.Fatal("expected error for invalid regex")
+	}
+	if _, err := New([]Rule{{Pattern: " ", State: StateError}}); err == nil {
+		t.Fatal("expected error for empty pattern")
+	}
+}
+
+func TestHasJam(t *testing.T) {
+	t.Parallel()
+
+	if !HasJam([]string{"Ready", "Paper jammed in duplexer"}) {
+		t.Fatal("expected jam")
+	}
+	if HasJam([]string{"Pajama mode"}) {
+		t.Fatal("unexpected jam match inside word")
+	}
+}
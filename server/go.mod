@@ -5,6 +5,7 @@ go 1.25.0
 require (
 	github.com/Masterminds/semver v1.4.2
 	github.com/coreos/go-oidc/v3 v3.16.0
+	github.com/eclipse/paho.mqtt.golang v1.5.1
 	github.com/gorilla/websocket v1.5.3
 	github.com/jackc/pgx/v5 v5.9.2
 	github.com/kardianos/service v1.2.4
@@ -4,13 +4,41 @@ import (
 	"context"
 	"crypto/tls"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 	"runtime"
 	"strings"
+	"sync"
 	"time"
 )
 
+// ErrCheckSkipped is returned by a HealthCheck that does not apply to this
+// server (for example artifact storage when release intake is disabled).
+var ErrCheckSkipped = errors.New("check skipped")
+
+// checkTimeout bounds each check run by /readyz and /livez.
+const checkTimeout = 5 * time.Second
+
+// HealthCheck is one named check run by /readyz or /livez.
+type HealthCheck struct {
+	Name string
+	// Optional checks are reported but do not fail the probe
+	Optional bool
+	// Check returns a short detail on success, or an error describing the failure
+	Check func(ctx context.Context) (string, error)
+}
+
+// CheckResult is the outcome of one HealthCheck.
+type CheckResult struct {
+	Name       string `json:"name"`
+	Status     string `json:"status"` // ok, fail or skipped
+	Optional   bool   `json:"optional,omitempty"`
+	Detail     string `json:"detail,omitempty"`
+	Error      string `json:"error,omitempty"`
+	DurationMS int64  `json:"duration_ms"`
+}
+
 // HealthAPI provides HTTP handlers for health checks and version information.
 type HealthAPI struct {
 	version         string
@@ -20,6 +48,8 @@ type HealthAPI struct {
 	protocolVersion string
 	processStart    time.Time
 	tenancyChecker  func() bool // Optional function to check if tenancy is enabled
+	readiness       []HealthCheck
+	liveness        []HealthCheck
 }
 
 // HealthAPIOptions configures the health API.
@@ -31,6 +61,10 @@ type HealthAPIOptions struct {
 	ProtocolVersion string
 	ProcessStart    time.Time
 	TenancyChecker  func() bool
+	// Checks behind /readyz (can the server take traffic) and /livez (should
+	// the process be restarted)
+	ReadinessChecks []HealthCheck
+	LivenessChecks  []HealthCheck
 }
 
 // NewHealthAPI creates a new health API instance.
@@ -43,6 +77,8 @@ func NewHealthAPI(opts HealthAPIOptions) *HealthAPI {
 		protocolVersion: opts.ProtocolVersion,
 		processStart:    opts.ProcessStart,
 		tenancyChecker:  opts.TenancyChecker,
+		readiness:       opts.ReadinessChecks,
+		liveness:        opts.LivenessChecks,
 	}
 }
 
@@ -53,6 +89,8 @@ func (api *HealthAPI) RegisterRoutes(mux *http.ServeMux) {
 	}
 	mux.HandleFunc("/health", api.HandleHealth)
 	mux.HandleFunc("/api/version", api.HandleVersion)
+	mux.HandleFunc("/readyz", api.HandleReadyz)
+	mux.HandleFunc("/livez", api.HandleLivez)
 }
 
 // HandleHealth handles GET /health - simple health check endpoint.
@@ -66,6 +104,90 @@ func (api *HealthAPI) HandleHealth(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
+// HandleReadyz handles GET /readyz - whether the server can take traffic.
+// Returns 503 when a required check fails. Public, for load balancers and
+// Kubernetes readiness probes; ?exclude=name skips a check.
+func (api *HealthAPI) HandleReadyz(w http.ResponseWriter, r *http.Request) {
+	api.serveChecks(w, r, api.readiness)
+}
+
+// HandleLivez handles GET /livez - whether the process is still working.
+// It leaves out external dependencies so a database outage does not get the
+// server restarted. Public, for Kubernetes liveness probes.
+func (api *HealthAPI) HandleLivez(w http.ResponseWriter, r *http.Request) {
+	api.serveChecks(w, r, api.liveness)
+}
+
+func (api *HealthAPI) serveChecks(w http.ResponseWriter, r *http.Request, checks []HealthCheck) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		http.Error(w, "GET only", http.StatusMethodNotAllowed)
+		return
+	}
+	excluded := make(map[string]bool)
+	for _, name := range r.URL.Query()["exclude"] {
+		excluded[strings.TrimSpace(name)] = true
+	}
+
+	results := RunHealthChecks(r.Context(), checks, excluded)
+	status := "ok"
+	for _, res := range results {
+		if res.Status != "fail" {
+			continue
+		}
+		if !res.Optional {
+			status = "fail"
+			break
+		}
+		status = "degraded"
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	if status == "fail" {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status":    status,
+		"timestamp": time.Now().UTC(),
+		"checks":    results,
+	})
+}
+
+// RunHealthChecks runs checks concurrently, each with its own timeout, and
+// returns their results in order. Checks named in excluded are skipped.
+func RunHealthChecks(ctx context.Context, checks []HealthCheck, excluded map[string]bool) []CheckResult {
+	results := make([]CheckResult, len(checks))
+	var wg sync.WaitGroup
+	for i, check := range checks {
+		results[i] = CheckResult{Name: check.Name, Optional: check.Optional}
+		if excluded[check.Name] || check.Check == nil {
+			results[i].Status = "skipped"
+			continue
+		}
+		wg.Add(1)
+		go func(res *CheckResult, check HealthCheck) {
+			defer wg.Done()
+			checkCtx, cancel := context.WithTimeout(ctx, checkTimeout)
+			defer cancel()
+			start := time.Now()
+			detail, err := check.Check(checkCtx)
+			res.DurationMS = time.Since(start).Milliseconds()
+			res.Detail = detail
+			switch {
+			case errors.Is(err, ErrCheckSkipped):
+				res.Status = "skipped"
+			case err != nil:
+				res.Status = "fail"
+				res.Error = err.Error()
+			default:
+				res.Status = "ok"
+			}
+		}(&results[i], check)
+	}
+	wg.Wait()
+	return results
+}
+
 // HandleVersion handles GET /api/version - returns server version information.
 // This endpoint is public (no authentication required).
 func (api *HealthAPI) HandleVersion(w http.ResponseWriter, r *http.Request) {
@@ -1,7 +1,9 @@
 package handlers
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"net/http"
 	"net/http/httptest"
 	"testing"
@@ -131,3 +133,58 @@ func TestRunHealthCheck_NoEndpoints(t *testing.T) {
 		t.Error("expected error when no valid endpoints configured")
 	}
 }
+
+func TestHealthAPI_HandleReadyz(t *testing.T) {
+	t.Parallel()
+
+	dbDown := true
+	api := NewHealthAPI(HealthAPIOptions{
+		ReadinessChecks: []HealthCheck{
+			{Name: "database", Check: func(ctx context.Context) (string, error) {
+				if dbDown {
+					return "", errors.New("connection refused")
+				}
+				return "ping 1ms", nil
+			}},
+			{Name: "artifact_storage", Optional: true, Check: func(ctx context.Context) (string, error) {
+				return "", errors.New("read-only file system")
+			}},
+			{Name: "jobs", Check: func(ctx context.Context) (string, error) {
+				return "", ErrCheckSkipped
+			}},
+		},
+	})
+
+	readyz := func(query string) (int, map[string]interface{}) {
+		w := httptest.NewRecorder()
+		api.HandleReadyz(w, httptest.NewRequest(http.MethodGet, "/readyz"+query, nil))
+		var resp map[string]interface{}
+		if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+			t.Fatalf("failed to decode response: %v", err)
+		}
+		return w.Code, resp
+	}
+
+	code, resp := readyz("")
+	if code != http.StatusServiceUnavailable || resp["status"] != "fail" {
+		t.Fatalf("expected 503/fail with the database down, got %d %v", code, resp["status"])
+	}
+	checks := resp["checks"].([]interface{})
+	first := checks[0].(map[string]interface{})
+	if first["name"] != "database" || first["status"] != "fail" || first["error"] != "connection refused" {
+		t.Fatalf("unexpected database result: %v", first)
+	}
+	if third := checks[2].(map[string]interface{}); third["status"] != "skipped" {
+		t.Fatalf("expected the jobs check to be skipped, got %v", third)
+	}
+
+	// Excluding the failing check leaves only the optional failure
+	if code, resp = readyz("?exclude=database"); code != http.StatusOK || resp["status"] != "degraded" {
+		t.Fatalf("expected 200/degraded, got %d %v", code, resp["status"])
+	}
+
+	dbDown = false
+	if code, _ = readyz(""); code != http.StatusOK {
+		t.Fatalf("expected 200 once the database is up, got %d", code)
+	}
+}
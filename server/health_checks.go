@@ -0,0 +1,132 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"printmaster/server/handlers"
+	"printmaster/server/storage"
+)
+
+// backgroundJob is a periodic worker watched by the health probes. A job is
+// stalled when its last run is older than staleAfter.
+type backgroundJob struct {
+	name       string
+	staleAfter time.Duration
+	lastRun    func() (time.Time, bool) // false when the job is not running
+}
+
+func backgroundJobs() []backgroundJob {
+	return []backgroundJob{
+		{name: "alert_evaluator", staleAfter: 5 * time.Minute, lastRun: func() (time.Time, bool) {
+			if alertEvaluator == nil {
+				return time.Time{}, false
+			}
+			return alertEvaluator.LastRun(), true
+		}},
+		{name: "metrics_collector", staleAfter: time.Minute, lastRun: func() (time.Time, bool) {
+			if metricsCollector == nil {
+				return time.Time{}, false
+			}
+			return metricsCollector.LastRun(), true
+		}},
+	}
+}
+
+// readinessChecks are the checks behind /readyz: everything the server needs
+// to serve requests. Artifact storage only affects agent updates, so it is
+// optional.
+func readinessChecks() []handlers.HealthCheck {
+	return []handlers.HealthCheck{
+		{Name: "database", Check: checkDatabase},
+		{Name: "migrations", Check: checkMigrations},
+		{Name: "background_jobs", Check: checkBackgroundJobs},
+		{Name: "artifact_storage", Optional: true, Check: checkArtifactStorage},
+	}
+}
+
+// livenessChecks are the checks behind /livez. They leave out the database
+// so an outage does not get every replica restarted.
+func livenessChecks() []handlers.HealthCheck {
+	return []handlers.HealthCheck{
+		{Name: "background_jobs", Check: checkBackgroundJobs},
+	}
+}
+
+func checkDatabase(ctx context.Context) (string, error) {
+	checker, ok := serverStore.(storage.HealthChecker)
+	if !ok {
+		return "", handlers.ErrCheckSkipped
+	}
+	start := time.Now()
+	if err := checker.Ping(ctx); err != nil {
+		return "", fmt.Errorf("ping failed: %w", err)
+	}
+	return fmt.Sprintf("ping %dms", time.Since(start).Milliseconds()), nil
+}
+
+func checkMigrations(ctx context.Context) (string, error) {
+	checker, ok := serverStore.(storage.HealthChecker)
+	if !ok {
+		return "", handlers.ErrCheckSkipped
+	}
+	applied, expected, err := checker.SchemaVersion(ctx)
+	if err != nil {
+		return "", fmt.Errorf("read schema version: %w", err)
+	}
+	if applied < expected {
+		return "", fmt.Errorf("schema version %d, expected %d", applied, expected)
+	}
+	return fmt.Sprintf("schema version %d", applied), nil
+}
+
+func checkBackgroundJobs(ctx context.Context) (string, error) {
+	now := time.Now()
+	var ran, stalled []string
+	for _, job := range backgroundJobs() {
+		last, running := job.lastRun()
+		if !running {
+			continue
+		}
+		if last.IsZero() {
+			// Not run yet: only a problem once the server has been up a while
+			if now.Sub(processStart) > job.staleAfter {
+				stalled = append(stalled, job.name+" never ran")
+			}
+			continue
+		}
+		age := now.Sub(last).Round(time.Second)
+		if age > job.staleAfter {
+			stalled = append(stalled, fmt.Sprintf("%s last ran %s ago", job.name, age))
+			continue
+		}
+		ran = append(ran, fmt.Sprintf("%s %s ago", job.name, age))
+	}
+	if len(stalled) > 0 {
+		return "", fmt.Errorf("stalled: %s", strings.Join(stalled, ", "))
+	}
+	if len(ran) == 0 {
+		return "", handlers.ErrCheckSkipped
+	}
+	return strings.Join(ran, ", "), nil
+}
+
+// checkArtifactStorage verifies the release cache agents download updates
+// from is writable.
+func checkArtifactStorage(ctx context.Context) (string, error) {
+	if intakeWorker == nil {
+		return "", handlers.ErrCheckSkipped
+	}
+	dir := intakeWorker.CacheDir()
+	probe, err := os.CreateTemp(dir, ".readyz-*")
+	if err != nil {
+		return "", fmt.Errorf("release cache %s not writable: %w", dir, err)
+	}
+	name := probe.Name()
+	probe.Close()
+	os.Remove(name)
+	return dir, nil
+}
@@ -0,0 +1,127 @@
+package imports
+
+import (
+	"sort"
+	"time"
+)
+
+// Counters are lifetime page counters at a point in time.
+type Counters struct {
+	Timestamp time.Time `json:"timestamp"`
+	Total     int       `json:"total"`
+	Mono      int       `json:"mono"`
+	Color     int       `json:"color"`
+	Scan      int       `json:"scan"`
+	Fax       int       `json:"fax"`
+}
+
+// History turns one device's records into lifetime counter points, oldest
+// first. anchor is the device's earliest stored snapshot, or nil if it has
+// none. Records at or after the anchor are dropped: PrintMaster already has
+// that period, and it also makes re-importing the same file a no-op.
+//
+// Lifetime records become points as they are. Volume records are summed per
+// UTC day and accumulated so the last day ends at the anchor's counters, or
+// counted up from zero when there is no anchor. Since volumes are bucketed by
+// day, the anchor's whole day is dropped.
+func History(records []Record, lifetime bool, anchor *Counters) []Counters {
+	var cutoff time.Time
+	if anchor != nil {
+		cutoff = anchor.Timestamp
+		if !lifetime {
+			cutoff = cutoff.UTC().Truncate(24 * time.Hour)
+		}
+	}
+	kept := make([]Record, 0, len(records))
+	for _, r := range records {
+		if anchor == nil || r.Timestamp.Before(cutoff) {
+			kept = append(kept, r)
+		}
+	}
+	sort.SliceStable(kept, func(i, j int) bool { return kept[i].Timestamp.Before(kept[j].Timestamp) })
+	if len(kept) == 0 {
+		return nil
+	}
+	if lifetime {
+		return lifetimeHistory(kept)
+	}
+	return volumeHistory(kept, anchor)
+}
+
+func lifetimeHistory(records []Record) []Counters {
+	points := make([]Counters, 0, len(records))
+	for _, r := range records {
+		c := Counters{Timestamp: r.Timestamp, Total: r.Total, Mono: r.Mono, Color: r.Color, Scan: r.Scan, Fax: r.Fax}
+		// Several readings at the same instant: keep the last one
+		if n := len(points); n > 0 && points[n-1].Timestamp.Equal(c.Timestamp) {
+			points[n-1] = c
+			continue
+		}
+		points = append(points, c)
+	}
+	return points
+}
+
+func volumeHistory(records []Record, anchor *Counters) []Counters {
+	// Daily buckets, stamped with the day's last record
+	var days []Counters
+	for _, r := range records {
+		n := len(days)
+		if n == 0 || !sameDay(days[n-1].Timestamp, r.Timestamp) {
+			days = append(days, Counters{})
+			n++
+		}
+		d := &days[n-1]
+		d.Timestamp = r.Timestamp
+		d.Total += r.Total
+		d.Mono += r.Mono
+		d.Color += r.Color
+		d.Scan += r.Scan
+		d.Fax += r.Fax
+	}
+
+	points := make([]Counters, len(days))
+	if anchor == nil {
+		var sum Counters
+		for i, d := range days {
+			sum.Total += d.Total
+			sum.Mono += d.Mono
+			sum.Color += d.Color
+			sum.Scan += d.Scan
+			sum.Fax += d.Fax
+			sum.Timestamp = d.Timestamp
+			points[i] = sum
+		}
+		return points
+	}
+
+	// Walk back from the anchor, removing each later day's volume
+	cur := *anchor
+	for i := len(days) - 1; i >= 0; i-- {
+		cur.Timestamp = days[i].Timestamp
+		points[i] = clampCounters(cur)
+		cur.Total -= days[i].Total
+		cur.Mono -= days[i].Mono
+		cur.Color -= days[i].Color
+		cur.Scan -= days[i].Scan
+		cur.Fax -= days[i].Fax
+	}
+	return points
+}
+
+func sameDay(a, b time.Time) bool {
+	ay, am, ad := a.UTC().Date()
+	by, bm, bd := b.UTC().Date()
+	return ay == by && am == bm && ad == bd
+}
+
+// clampCounters keeps counters non-negative when the imported volumes exceed
+// what the device has printed since its counters were last reset.
+func clampCounters(c Counters) Counters {
+	for _, v := range []*int{&c.Total, &c.Mono, &c.Color, &c.Scan, &c.Fax} {
+		if *v < 0 {
+			*v = 0
+		}
+	}
+	return c
+}
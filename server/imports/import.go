@@ -0,0 +1,151 @@
+package imports
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"printmaster/server/storage"
+)
+
+// Store is the subset of storage.Store used to import history.
+type Store interface {
+	GetDevice(ctx context.Context, serial string) (*storage.Device, error)
+	GetMetricsBounds(ctx context.Context, serial string) (minTS, maxTS time.Time, count int64, err error)
+	GetMetricsAtOrBefore(ctx context.Context, serial string, at time.Time) (*storage.MetricsSnapshot, error)
+	SaveMetrics(ctx context.Context, metrics *storage.MetricsSnapshot) error
+}
+
+// DeviceResult summarizes the history imported for one device.
+type DeviceResult struct {
+	Serial string     `json:"serial"`
+	Name   string     `json:"name,omitempty"`
+	Rows   int        `json:"rows"`
+	Points int        `json:"points"`
+	From   *time.Time `json:"from,omitempty"`
+	To     *time.Time `json:"to,omitempty"`
+	// HistoryStart is the first snapshot PrintMaster already had; rows from
+	// then on were dropped.
+	HistoryStart *time.Time `json:"history_start,omitempty"`
+}
+
+// Unmatched lists export rows whose serial could not be imported.
+type Unmatched struct {
+	Serial     string `json:"serial"`
+	DeviceName string `json:"device_name,omitempty"`
+	Rows       int    `json:"rows"`
+	Reason     string `json:"reason"`
+}
+
+// Summary is the outcome of an import or a dry run.
+type Summary struct {
+	Source    string         `json:"source"`
+	DryRun    bool           `json:"dry_run"`
+	Rows      int            `json:"rows"`
+	Imported  int            `json:"imported_points"`
+	Devices   []DeviceResult `json:"devices"`
+	Unmatched []Unmatched    `json:"unmatched"`
+	Skipped   []RowError     `json:"skipped,omitempty"`
+}
+
+// Import stores parsed records as metrics history of the devices they name.
+// Serials PrintMaster does not know, or that allow rejects (e.g. devices of
+// another tenant), are reported as unmatched. With dryRun nothing is stored.
+func Import(ctx context.Context, store Store, parsed *Parsed, dryRun bool, allow func(*storage.Device) bool) (*Summary, error) {
+	sum := &Summary{
+		Source:    parsed.Source,
+		DryRun:    dryRun,
+		Rows:      len(parsed.Records),
+		Devices:   []DeviceResult{},
+		Unmatched: []Unmatched{},
+		Skipped:   parsed.Skipped,
+	}
+
+	bySerial := make(map[string][]Record)
+	for _, r := range parsed.Records {
+		bySerial[r.Serial] = append(bySerial[r.Serial], r)
+	}
+	serials := make([]string, 0, len(bySerial))
+	for s := range bySerial {
+		serials = append(serials, s)
+	}
+	sort.Strings(serials)
+
+	for _, serial := range serials {
+		records := bySerial[serial]
+		device, err := store.GetDevice(ctx, serial)
+		if err != nil && !strings.Contains(strings.ToLower(err.Error()), "not found") {
+			return nil, fmt.Errorf("load device %s: %w", serial, err)
+		}
+		if device == nil || (allow != nil && !allow(device)) {
+			sum.Unmatched = append(sum.Unmatched, Unmatched{
+				Serial:     serial,
+				DeviceName: records[0].DeviceName,
+				Rows:       len(records),
+				Reason:     "no device with this serial",
+			})
+			continue
+		}
+
+		anchor, err := earliestCounters(ctx, store, serial)
+		if err != nil {
+			return nil, fmt.Errorf("load history of %s: %w", serial, err)
+		}
+		points := History(records, parsed.Lifetime, anchor)
+
+		res := DeviceResult{Serial: serial, Name: records[0].DeviceName, Rows: len(records), Points: len(points)}
+		if anchor != nil {
+			res.HistoryStart = &anchor.Timestamp
+		}
+		if len(points) > 0 {
+			res.From = &points[0].Timestamp
+			res.To = &points[len(points)-1].Timestamp
+		}
+		if !dryRun {
+			for _, p := range points {
+				if err := store.SaveMetrics(ctx, &storage.MetricsSnapshot{
+					Serial:     serial,
+					AgentID:    device.AgentID,
+					Timestamp:  p.Timestamp,
+					PageCount:  p.Total,
+					MonoPages:  p.Mono,
+					ColorPages: p.Color,
+					ScanCount:  p.Scan,
+					FaxPages:   p.Fax,
+				}); err != nil {
+					return nil, fmt.Errorf("save history of %s: %w", serial, err)
+				}
+				sum.Imported++
+			}
+		}
+		sum.Devices = append(sum.Devices, res)
+	}
+	return sum, nil
+}
+
+// earliestCounters returns the device's first stored snapshot, or nil.
+func earliestCounters(ctx context.Context, store Store, serial string) (*Counters, error) {
+	minTS, _, _, err := store.GetMetricsBounds(ctx, serial)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	m, err := store.GetMetricsAtOrBefore(ctx, serial, minTS)
+	if err != nil || m == nil {
+		return nil, err
+	}
+	return &Counters{
+		Timestamp: m.Timestamp,
+		Total:     m.PageCount,
+		Mono:      m.MonoPages,
+		Color:     m.ColorPages,
+		Scan:      m.ScanCount,
+		Fax:       m.FaxPages,
+	}, nil
+}
@@ -0,0 +1,174 @@
+package imports
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"printmaster/server/storage"
+)
+
+const paperCutLog = `PaperCut Print Logger : http://www.papercut.com/
+Time,User,Pages,Copies,Printer,Document Name,Client,Paper Size,Language,Height,Width,Duplex,Grayscale,Size
+2024-03-01 09:15:02,alice,3,2,Office-MFP,report.pdf,PC1,A4,PCL6,297,210,DUPLEX,GRAYSCALE,120kb
+2024-03-01 16:40:11,bob,5,1,Office-MFP,slides.pptx,PC2,A4,PCL6,297,210,NOT DUPLEX,NOT GRAYSCALE,2mb
+2024-03-02 10:00:00,carol,4,1,Office-MFP,memo.docx,PC3,A4,PCL6,297,210,NOT DUPLEX,GRAYSCALE,50kb
+2024-03-02 11:00:00,dave,1,1,Lobby-Printer,x.txt,PC4,A4,PCL6,297,210,NOT DUPLEX,GRAYSCALE,1kb
+`
+
+const safeQReport = `Serial number;Device name;Reading date;Total;B&W;Color;Scan
+`
+
+func TestParsePaperCutLogWithNameMapping(t *testing.T) {
+	parsed, err := Parse(SourcePaperCut, strings.NewReader(paperCutLog), Options{Serials: map[string]string{"office-mfp": "SN-1"}})
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	if parsed.Lifetime || len(parsed.Records) != 3 || len(parsed.Skipped) != 1 {
+		t.Fatalf("unexpected parse: %d records, skipped %+v", len(parsed.Records), parsed.Skipped)
+	}
+	if r := parsed.Records[0]; r.Serial != "SN-1" || r.Total != 6 || r.Mono != 6 || r.Color != 0 {
+		t.Fatalf("grayscale job with copies: %+v", r)
+	}
+	if r := parsed.Records[1]; r.Total != 5 || r.Color != 5 {
+		t.Fatalf("color job: %+v", r)
+	}
+	if parsed.Skipped[0].Reason != "missing serial number" {
+		t.Fatalf("unmapped printer should be skipped: %+v", parsed.Skipped)
+	}
+}
+
+func TestParseSafeQCounters(t *testing.T) {
+	csv := "Serial number,Device name,Reading date,Total,B&W,Color,Scan\n" +
+		"SN-2,Floor 2,01.02.2024,\"12,000\",10000,2000,300\n" +
+		"SN-2,Floor 2,not a date,1,1,0,0\n"
+	parsed, err := Parse(SourceSafeQ, strings.NewReader(csv), Options{})
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	if !parsed.Lifetime || len(parsed.Records) != 1 || len(parsed.Skipped) != 1 {
+		t.Fatalf("unexpected parse: %+v", parsed)
+	}
+	r := parsed.Records[0]
+	want := time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC)
+	if r.Serial != "SN-2" || r.Total != 12000 || r.Mono != 10000 || r.Color != 2000 || r.Scan != 300 || !r.Timestamp.Equal(want) {
+		t.Fatalf("unexpected record: %+v", r)
+	}
+
+	if _, err := Parse("printlogger", strings.NewReader(csv), Options{}); err == nil {
+		t.Fatal("expected unknown source to fail")
+	}
+	if _, err := Parse(SourceSafeQ, strings.NewReader(safeQReport), Options{}); err == nil {
+		t.Fatal("expected semicolon export without a recognizable header to fail")
+	}
+}
+
+func TestHistoryAnchorsVolumesToStoredCounters(t *testing.T) {
+	day := func(d, h int) time.Time { return time.Date(2024, 3, d, h, 0, 0, 0, time.UTC) }
+	records := []Record{
+		{Timestamp: day(1, 9), Total: 10, Mono: 10},
+		{Timestamp: day(1, 17), Total: 5, Color: 5},
+		{Timestamp: day(2, 10), Total: 20, Mono: 20},
+		{Timestamp: day(5, 8), Total: 99, Mono: 99}, // after the anchor
+	}
+
+	points := History(records, false, nil)
+	if len(points) != 3 || points[0].Total != 15 || points[1].Total != 35 || points[2].Total != 134 {
+		t.Fatalf("unanchored history: %+v", points)
+	}
+
+	anchor := &Counters{Timestamp: day(4, 0), Total: 1000, Mono: 800, Color: 200}
+	points = History(records, false, anchor)
+	if len(points) != 2 {
+		t.Fatalf("expected 2 daily points, got %+v", points)
+	}
+	if p := points[1]; p.Total != 1000 || p.Mono != 800 || !p.Timestamp.Equal(day(2, 10)) {
+		t.Fatalf("last day must end at the anchor: %+v", p)
+	}
+	if p := points[0]; p.Total != 980 || p.Mono != 780 || p.Color != 200 || !p.Timestamp.Equal(day(1, 17)) {
+		t.Fatalf("first day: %+v", p)
+	}
+}
+
+type fakeStore struct {
+	devices map[string]*storage.Device
+	metrics map[string][]*storage.MetricsSnapshot
+}
+
+func (f *fakeStore) GetDevice(ctx context.Context, serial string) (*storage.Device, error) {
+	if d, ok := f.devices[serial]; ok {
+		return d, nil
+	}
+	return nil, fmt.Errorf("device not found: %s", serial)
+}
+
+func (f *fakeStore) GetMetricsBounds(ctx context.Context, serial string) (time.Time, time.Time, int64, error) {
+	ms := f.metrics[serial]
+	if len(ms) == 0 {
+		return time.Time{}, time.Time{}, 0, sql.ErrNoRows
+	}
+	minTS, maxTS := ms[0].Timestamp, ms[0].Timestamp
+	for _, m := range ms {
+		if m.Timestamp.Before(minTS) {
+			minTS = m.Timestamp
+		}
+		if m.Timestamp.After(maxTS) {
+			maxTS = m.Timestamp
+		}
+	}
+	return minTS, maxTS, int64(len(ms)), nil
+}
+
+func (f *fakeStore) GetMetricsAtOrBefore(ctx context.Context, serial string, at time.Time) (*storage.MetricsSnapshot, error) {
+	var best *storage.MetricsSnapshot
+	for _, m := range f.metrics[serial] {
+		if !m.Timestamp.After(at) && (best == nil || m.Timestamp.After(best.Timestamp)) {
+			best = m
+		}
+	}
+	return best, nil
+}
+
+func (f *fakeStore) SaveMetrics(ctx context.Context, m *storage.MetricsSnapshot) error {
+	f.metrics[m.Serial] = append(f.metrics[m.Serial], m)
+	return nil
+}
+
+func TestImportIsIdempotent(t *testing.T) {
+	dev := &storage.Device{AgentID: "agent-1"}
+	dev.Serial = "SN-1"
+	store := &fakeStore{
+		devices: map[string]*storage.Device{"SN-1": dev},
+		metrics: map[string][]*storage.MetricsSnapshot{
+			"SN-1": {{Serial: "SN-1", Timestamp: time.Date(2024, 4, 1, 0, 0, 0, 0, time.UTC), PageCount: 500, MonoPages: 500}},
+		},
+	}
+	parsed, err := Parse(SourcePaperCut, strings.NewReader(paperCutLog), Options{Serials: map[string]string{"Office-MFP": "SN-1", "Lobby-Printer": "SN-9"}})
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+
+	dry, err := Import(context.Background(), store, parsed, true, nil)
+	if err != nil || dry.Imported != 0 || len(dry.Devices) != 1 || dry.Devices[0].Points != 2 {
+		t.Fatalf("dry run: %+v, %v", dry, err)
+	}
+	if len(dry.Unmatched) != 1 || dry.Unmatched[0].Serial != "SN-9" {
+		t.Fatalf("expected SN-9 unmatched: %+v", dry.Unmatched)
+	}
+
+	sum, err := Import(context.Background(), store, parsed, false, nil)
+	if err != nil || sum.Imported != 2 || len(store.metrics["SN-1"]) != 3 {
+		t.Fatalf("import: %+v, %v", sum, err)
+	}
+	if got := store.metrics["SN-1"][2]; got.PageCount != 500 || got.AgentID != "agent-1" {
+		t.Fatalf("last imported point must meet stored history: %+v", got)
+	}
+
+	again, err := Import(context.Background(), store, parsed, false, nil)
+	if err != nil || again.Imported != 0 {
+		t.Fatalf("re-import should store nothing: %+v, %v", again, err)
+	}
+}
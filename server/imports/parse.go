@@ -0,0 +1,336 @@
+// Package imports ingests device and counter exports from third-party print
+// management systems (PaperCut, SafeQ) so customers migrating to PrintMaster
+// keep their historical volumes instead of starting from zero. Exports are
+// matched to PrintMaster devices by serial number and stored as metrics
+// history that ends where the device's own history begins.
+package imports
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// Supported export sources.
+const (
+	// SourcePaperCut reads PaperCut print logs or per-printer usage reports.
+	// Rows are page volumes (per job or per period), not lifetime counters.
+	SourcePaperCut = "papercut"
+	// SourceSafeQ reads YSoft SafeQ device counter reports: lifetime meter
+	// readings per device and date.
+	SourceSafeQ = "safeq"
+)
+
+// ErrUnknownSource is returned for sources other than the supported ones.
+var ErrUnknownSource = errors.New("unknown import source")
+
+// Record is one row of an export. For lifetime sources the counters are
+// meter readings at Timestamp; otherwise they are pages printed in the job
+// or period ending at Timestamp.
+type Record struct {
+	Line       int       `json:"line"`
+	Serial     string    `json:"serial"`
+	DeviceName string    `json:"device_name,omitempty"`
+	Timestamp  time.Time `json:"timestamp"`
+	Total      int       `json:"total"`
+	Mono       int       `json:"mono"`
+	Color      int       `json:"color"`
+	Scan       int       `json:"scan"`
+	Fax        int       `json:"fax"`
+}
+
+// RowError explains why a row was skipped.
+type RowError struct {
+	Line   int    `json:"line"`
+	Reason string `json:"reason"`
+}
+
+// Parsed is the result of reading an export.
+type Parsed struct {
+	Source string `json:"source"`
+	// Lifetime is true when counters are meter readings rather than volumes.
+	Lifetime bool       `json:"lifetime"`
+	Records  []Record   `json:"records"`
+	Skipped  []RowError `json:"skipped,omitempty"`
+}
+
+// Options tune parsing.
+type Options struct {
+	// DateLayout is a Go time layout tried before the built-in ones, for
+	// exports with locale-specific dates (e.g. "02.01.2006 15:04").
+	DateLayout string
+	// Location is used for timestamps without a zone; defaults to UTC.
+	Location *time.Location
+	// Serials maps device names to serial numbers, for exports without a
+	// serial column (PaperCut print logs only name the printer). Names are
+	// matched case-insensitively.
+	Serials map[string]string
+}
+
+// Column aliases per field, compared after normalizeHeader. The first
+// matching column wins.
+var (
+	serialColumns = []string{"serialnumber", "serial", "deviceserialnumber", "deviceserial", "printerserialnumber", "printerserial", "sn"}
+	nameColumns   = []string{"printer", "printername", "devicename", "device", "name"}
+	timeColumns   = []string{"time", "datetime", "date", "usagedate", "readingdate", "readdate", "timestamp", "period", "enddate"}
+
+	paperCutPagesColumns  = []string{"totalpages", "pages", "printedpages"}
+	paperCutCopiesColumns = []string{"copies"}
+	paperCutGrayColumns   = []string{"grayscale", "greyscale", "colormode", "colourmode"}
+	paperCutMonoColumns   = []string{"grayscalepages", "greyscalepages", "monopages", "bwpages", "blackandwhitepages"}
+	paperCutColorColumns  = []string{"colorpages", "colourpages"}
+
+	safeQTotalColumns = []string{"total", "totalcounter", "totalpages", "totalcount", "lifetimecounter", "counter"}
+	safeQMonoColumns  = []string{"mono", "monocounter", "bw", "bandw", "bwcounter", "blackandwhite", "monopages", "bwpages"}
+	safeQColorColumns = []string{"color", "colour", "colorcounter", "colourcounter", "colorpages", "colourpages"}
+	safeQScanColumns  = []string{"scan", "scans", "scancounter", "scanpages"}
+	safeQFaxColumns   = []string{"fax", "faxcounter", "faxpages"}
+)
+
+var dateLayouts = []string{
+	time.RFC3339,
+	"2006-01-02 15:04:05",
+	"2006-01-02 15:04",
+	"2006-01-02T15:04:05",
+	"2006-01-02",
+	"01/02/2006 3:04:05 PM",
+	"01/02/2006 15:04:05",
+	"01/02/2006 15:04",
+	"01/02/2006",
+	"02.01.2006 15:04:05",
+	"02.01.2006 15:04",
+	"02.01.2006",
+	"2006-01", // monthly summaries
+}
+
+// Parse reads a CSV export from source. Rows that cannot be used are listed
+// in Skipped; an error is returned only when the file itself is unusable.
+func Parse(source string, r io.Reader, opts Options) (*Parsed, error) {
+	source = strings.ToLower(strings.TrimSpace(source))
+	if source != SourcePaperCut && source != SourceSafeQ {
+		return nil, fmt.Errorf("%w: %q", ErrUnknownSource, source)
+	}
+	if opts.Location == nil {
+		opts.Location = time.UTC
+	}
+	serials := make(map[string]string, len(opts.Serials))
+	for name, serial := range opts.Serials {
+		serials[strings.ToLower(strings.TrimSpace(name))] = strings.TrimSpace(serial)
+	}
+
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+	cr.TrimLeadingSpace = true
+	cr.LazyQuotes = true
+
+	// PaperCut print logs start with a title line before the header, so the
+	// header is the first row that names a serial column (or a device name
+	// column when names are mapped to serials).
+	cols := columns{serial: -1, name: -1, time: -1}
+	line := 0
+	for cols.serial < 0 && (len(serials) == 0 || cols.name < 0) {
+		row, err := cr.Read()
+		if err == io.EOF {
+			return nil, fmt.Errorf("no header with a serial number column found")
+		}
+		if err != nil {
+			return nil, fmt.Errorf("read csv: %w", err)
+		}
+		line++
+		cols = findColumns(row)
+	}
+	if cols.time < 0 {
+		return nil, fmt.Errorf("no date or time column found")
+	}
+
+	out := &Parsed{Source: source, Lifetime: source == SourceSafeQ, Records: []Record{}}
+	for {
+		row, err := cr.Read()
+		if err == io.EOF {
+			break
+		}
+		line++
+		if err != nil {
+			out.Skipped = append(out.Skipped, RowError{Line: line, Reason: err.Error()})
+			continue
+		}
+		if isBlank(row) {
+			continue
+		}
+		rec, err := parseRow(source, cols, row, opts, serials)
+		if err != nil {
+			out.Skipped = append(out.Skipped, RowError{Line: line, Reason: err.Error()})
+			continue
+		}
+		rec.Line = line
+		out.Records = append(out.Records, rec)
+	}
+	return out, nil
+}
+
+type columns struct {
+	header             map[string]int
+	serial, name, time int
+}
+
+func findColumns(row []string) columns {
+	c := columns{header: make(map[string]int, len(row))}
+	for i, h := range row {
+		key := normalizeHeader(h)
+		if _, dup := c.header[key]; !dup && key != "" {
+			c.header[key] = i
+		}
+	}
+	c.serial = c.index(serialColumns)
+	c.name = c.index(nameColumns)
+	c.time = c.index(timeColumns)
+	return c
+}
+
+func (c columns) index(aliases []string) int {
+	for _, a := range aliases {
+		if i, ok := c.header[a]; ok {
+			return i
+		}
+	}
+	return -1
+}
+
+func normalizeHeader(h string) string {
+	var b strings.Builder
+	for _, r := range strings.ToLower(h) {
+		if r == '&' {
+			b.WriteString("and")
+		} else if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+func parseRow(source string, cols columns, row []string, opts Options, serials map[string]string) (Record, error) {
+	field := func(i int) string {
+		if i < 0 || i >= len(row) {
+			return ""
+		}
+		return strings.TrimSpace(row[i])
+	}
+	number := func(aliases []string) (int, error) {
+		i := cols.index(aliases)
+		v := strings.ReplaceAll(field(i), ",", "")
+		if v == "" {
+			return 0, nil
+		}
+		n, err := strconv.ParseFloat(v, 64)
+		if err != nil || n < 0 {
+			return 0, fmt.Errorf("invalid number %q", field(i))
+		}
+		return int(n), nil
+	}
+
+	rec := Record{Serial: field(cols.serial), DeviceName: field(cols.name)}
+	if rec.Serial == "" && rec.DeviceName != "" {
+		rec.Serial = serials[strings.ToLower(rec.DeviceName)]
+	}
+	if rec.Serial == "" {
+		return rec, fmt.Errorf("missing serial number")
+	}
+	ts, err := parseTime(field(cols.time), opts)
+	if err != nil {
+		return rec, err
+	}
+	rec.Timestamp = ts
+
+	if source == SourceSafeQ {
+		for _, f := range []struct {
+			dst     *int
+			aliases []string
+		}{
+			{&rec.Total, safeQTotalColumns},
+			{&rec.Mono, safeQMonoColumns},
+			{&rec.Color, safeQColorColumns},
+			{&rec.Scan, safeQScanColumns},
+			{&rec.Fax, safeQFaxColumns},
+		} {
+			if *f.dst, err = number(f.aliases); err != nil {
+				return rec, err
+			}
+		}
+	} else {
+		// Per-printer reports carry mono/color columns; print logs carry
+		// pages x copies and a grayscale flag per job.
+		if rec.Mono, err = number(paperCutMonoColumns); err != nil {
+			return rec, err
+		}
+		if rec.Color, err = number(paperCutColorColumns); err != nil {
+			return rec, err
+		}
+		if rec.Total, err = number(paperCutPagesColumns); err != nil {
+			return rec, err
+		}
+		if cols.index(paperCutCopiesColumns) >= 0 {
+			copies, err := number(paperCutCopiesColumns)
+			if err != nil {
+				return rec, err
+			}
+			if copies > 0 {
+				rec.Total *= copies
+			}
+		}
+		if rec.Mono == 0 && rec.Color == 0 && rec.Total > 0 {
+			if isGrayscale(field(cols.index(paperCutGrayColumns))) {
+				rec.Mono = rec.Total
+			} else {
+				rec.Color = rec.Total
+			}
+		}
+	}
+	if rec.Total == 0 {
+		rec.Total = rec.Mono + rec.Color
+	}
+	if rec.Total == 0 && rec.Scan == 0 && rec.Fax == 0 {
+		return rec, fmt.Errorf("no page counts")
+	}
+	return rec, nil
+}
+
+// isGrayscale interprets PaperCut's grayscale column ("GRAYSCALE" /
+// "NOT GRAYSCALE") and common yes/no variants. Jobs without the column are
+// counted as mono, the cheaper assumption.
+func isGrayscale(v string) bool {
+	switch strings.ToLower(strings.TrimSpace(v)) {
+	case "not grayscale", "not greyscale", "no", "false", "0", "color", "colour":
+		return false
+	}
+	return true
+}
+
+func parseTime(v string, opts Options) (time.Time, error) {
+	if v == "" {
+		return time.Time{}, fmt.Errorf("missing date")
+	}
+	layouts := dateLayouts
+	if opts.DateLayout != "" {
+		layouts = append([]string{opts.DateLayout}, dateLayouts...)
+	}
+	for _, layout := range layouts {
+		if t, err := time.ParseInLocation(layout, v, opts.Location); err == nil {
+			return t.UTC(), nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("unrecognized date %q", v)
+}
+
+func isBlank(row []string) bool {
+	for _, v := range row {
+		if strings.TrimSpace(v) != "" {
+			return false
+		}
+	}
+	return true
+}
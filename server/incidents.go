@@ -0,0 +1,422 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	authz "printmaster/server/authz"
+	"printmaster/server/storage"
+)
+
+// incidentCreateRequest raises an incident by hand. The tenant comes from the
+// device when a serial is given.
+type incidentCreateRequest struct {
+	Title       string  `json:"title"`
+	Description string  `json:"description"`
+	Severity    string  `json:"severity"`
+	Assignee    string  `json:"assignee"`
+	Serial      string  `json:"serial"`
+	TenantID    string  `json:"tenant_id"`
+	AlertIDs    []int64 `json:"alert_ids"`
+}
+
+// incidentUpdateRequest changes an incident. Omitted fields are kept; Note is
+// added to the history along with the change.
+type incidentUpdateRequest struct {
+	Title       *string `json:"title"`
+	Description *string `json:"description"`
+	Severity    *string `json:"severity"`
+	Status      *string `json:"status"`
+	Assignee    *string `json:"assignee"`
+	Note        string  `json:"note"`
+}
+
+// incidentDetail is the single-incident response: the incident with its
+// linked alerts and history.
+type incidentDetail struct {
+	*storage.Incident
+	Alerts []*storage.Alert        `json:"alerts"`
+	Notes  []*storage.IncidentNote `json:"notes"`
+}
+
+// handleIncidents lists incidents (GET ?status=&open=&assignee=&serial=&limit=)
+// or raises one by hand (POST).
+func handleIncidents(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	switch r.Method {
+	case http.MethodGet:
+		if !authorizeOrReject(w, r, authz.ActionIncidentsRead, authz.ResourceRef{}) {
+			return
+		}
+		q := r.URL.Query()
+		filter := storage.IncidentFilter{
+			Assignee:     strings.TrimSpace(q.Get("assignee")),
+			DeviceSerial: strings.TrimSpace(q.Get("serial")),
+			OpenOnly:     q.Get("open") == "true",
+			Limit:        200,
+		}
+		if statuses := strings.TrimSpace(q.Get("status")); statuses != "" {
+			for _, st := range strings.Split(statuses, ",") {
+				if st = strings.TrimSpace(st); !storage.IsIncidentStatus(st) {
+					http.Error(w, "invalid status", http.StatusBadRequest)
+					return
+				}
+				filter.Statuses = append(filter.Statuses, st)
+			}
+		}
+		if v := strings.TrimSpace(q.Get("limit")); v != "" {
+			var err error
+			if filter.Limit, err = strconv.Atoi(v); err != nil || filter.Limit <= 0 {
+				http.Error(w, "invalid limit", http.StatusBadRequest)
+				return
+			}
+		}
+		principal := getPrincipal(r)
+		if principal != nil && !principal.IsAdmin() {
+			filter.TenantIDs = principal.AllowedTenantIDs()
+			if len(filter.TenantIDs) == 0 {
+				writeIncidents(w, nil)
+				return
+			}
+		}
+		incidents, err := serverStore.ListIncidents(ctx, filter)
+		if err != nil {
+			logError("Failed to list incidents", "error", err)
+			http.Error(w, "failed to list incidents", http.StatusInternalServerError)
+			return
+		}
+		writeIncidents(w, incidents)
+	case http.MethodPost:
+		var req incidentCreateRequest
+		if err := decodeJSONBody(r, &req); err != nil {
+			http.Error(w, "invalid JSON", http.StatusBadRequest)
+			return
+		}
+		inc := &storage.Incident{
+			Title:       req.Title,
+			Description: req.Description,
+			Severity:    req.Severity,
+			Assignee:    req.Assignee,
+			Status:      storage.IncidentStatusOpen,
+			Source:      storage.IncidentSourceManual,
+			TenantID:    strings.TrimSpace(req.TenantID),
+		}
+		if serial := strings.TrimSpace(req.Serial); serial != "" {
+			device, tenantID, ok := resolveNoteDevice(ctx, r, serial)
+			if !ok {
+				http.Error(w, "device not found", http.StatusNotFound)
+				return
+			}
+			inc.DeviceSerial, inc.AgentID, inc.TenantID = device.Serial, device.AgentID, tenantID
+		} else if inc.TenantID == "" {
+			if principal := getPrincipal(r); principal != nil && !principal.IsAdmin() {
+				allowed := principal.AllowedTenantIDs()
+				if len(allowed) != 1 {
+					http.Error(w, "tenant_id is required", http.StatusBadRequest)
+					return
+				}
+				inc.TenantID = allowed[0]
+			}
+		}
+		if !authorizeOrReject(w, r, authz.ActionIncidentsWrite, authz.ResourceRef{TenantIDs: []string{inc.TenantID}}) {
+			return
+		}
+		if err := inc.Validate(); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if !checkIncidentAlerts(ctx, w, r, inc, req.AlertIDs) {
+			return
+		}
+		_, _, actorName, _ := auditActorFromPrincipal(r)
+		inc.CreatedBy = actorName
+		inc.AlertIDs = req.AlertIDs
+		if err := serverStore.CreateIncident(ctx, inc); err != nil {
+			logError("Failed to create incident", "error", err)
+			http.Error(w, "failed to create incident", http.StatusInternalServerError)
+			return
+		}
+		auditIncident(r, "incident.create", inc, "")
+		broadcastIncident("created", inc)
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(inc)
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// handleIncident serves one incident:
+//
+//	GET   /api/v1/incidents/{id}         incident with alerts and notes
+//	PATCH /api/v1/incidents/{id}         change status, assignee, title, ...
+//	POST  /api/v1/incidents/{id}/notes   add a note
+//	POST  /api/v1/incidents/{id}/alerts  link more alerts
+func handleIncident(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	rest := strings.TrimPrefix(r.URL.Path, "/api/v1/incidents/")
+	idPart, sub, _ := strings.Cut(rest, "/")
+	id, err := strconv.ParseInt(idPart, 10, 64)
+	if err != nil {
+		http.Error(w, "invalid incident id", http.StatusBadRequest)
+		return
+	}
+	inc, err := serverStore.GetIncident(ctx, id)
+	if err != nil {
+		logError("Failed to load incident", "id", id, "error", err)
+		http.Error(w, "failed to load incident", http.StatusInternalServerError)
+		return
+	}
+	scope, ok := tenantScope(getPrincipal(r))
+	if inc == nil || !ok || !tenantAllowed(scope, inc.TenantID) {
+		http.Error(w, "incident not found", http.StatusNotFound)
+		return
+	}
+	resource := authz.ResourceRef{TenantIDs: []string{inc.TenantID}}
+
+	switch {
+	case sub == "" && r.Method == http.MethodGet:
+		if !authorizeOrReject(w, r, authz.ActionIncidentsRead, resource) {
+			return
+		}
+		detail := incidentDetail{Incident: inc, Alerts: []*storage.Alert{}}
+		for _, alertID := range inc.AlertIDs {
+			if a, err := serverStore.GetAlert(ctx, alertID); err == nil && a != nil {
+				detail.Alerts = append(detail.Alerts, a)
+			}
+		}
+		if detail.Notes, err = serverStore.ListIncidentNotes(ctx, inc.ID); err != nil {
+			logError("Failed to list incident notes", "id", id, "error", err)
+			http.Error(w, "failed to load incident", http.StatusInternalServerError)
+			return
+		}
+		if detail.Notes == nil {
+			detail.Notes = []*storage.IncidentNote{}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(detail)
+	case sub == "" && (r.Method == http.MethodPatch || r.Method == http.MethodPut):
+		if !authorizeOrReject(w, r, authz.ActionIncidentsWrite, resource) {
+			return
+		}
+		var req incidentUpdateRequest
+		if err := decodeJSONBody(r, &req); err != nil {
+			http.Error(w, "invalid JSON", http.StatusBadRequest)
+			return
+		}
+		updateIncident(w, r, inc, req)
+	case sub == "notes" && r.Method == http.MethodPost:
+		if !authorizeOrReject(w, r, authz.ActionIncidentsWrite, resource) {
+			return
+		}
+		var req struct {
+			Body string `json:"body"`
+		}
+		if err := decodeJSONBody(r, &req); err != nil {
+			http.Error(w, "invalid JSON", http.StatusBadRequest)
+			return
+		}
+		_, _, actorName, _ := auditActorFromPrincipal(r)
+		note := &storage.IncidentNote{IncidentID: inc.ID, Kind: storage.IncidentNoteKindNote, Body: req.Body, Author: actorName}
+		if strings.TrimSpace(note.Body) == "" || len(note.Body) > storage.MaxIncidentNoteLength {
+			http.Error(w, fmt.Sprintf("body is required and must be at most %d characters", storage.MaxIncidentNoteLength), http.StatusBadRequest)
+			return
+		}
+		if err := serverStore.AddIncidentNote(ctx, note); err != nil {
+			logError("Failed to add incident note", "id", id, "error", err)
+			http.Error(w, "failed to add note", http.StatusInternalServerError)
+			return
+		}
+		auditIncident(r, "incident.note", inc, fmt.Sprintf("note=%d", note.ID))
+		broadcastIncident("noted", inc)
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(note)
+	case sub == "alerts" && r.Method == http.MethodPost:
+		if !authorizeOrReject(w, r, authz.ActionIncidentsWrite, resource) {
+			return
+		}
+		var req struct {
+			AlertIDs []int64 `json:"alert_ids"`
+		}
+		if err := decodeJSONBody(r, &req); err != nil || len(req.AlertIDs) == 0 {
+			http.Error(w, "alert_ids is required", http.StatusBadRequest)
+			return
+		}
+		if !checkIncidentAlerts(ctx, w, r, inc, req.AlertIDs) {
+			return
+		}
+		alertIDs := req.AlertIDs
+		if err := serverStore.LinkIncidentAlerts(ctx, inc.ID, alertIDs); err != nil {
+			logError("Failed to link incident alerts", "id", id, "error", err)
+			http.Error(w, "failed to link alerts", http.StatusInternalServerError)
+			return
+		}
+		ids := make([]string, len(alertIDs))
+		for i, alertID := range alertIDs {
+			ids[i] = "#" + strconv.FormatInt(alertID, 10)
+		}
+		_, _, actorName, _ := auditActorFromPrincipal(r)
+		addIncidentHistory(ctx, inc.ID, storage.IncidentNoteKindAlerts, "Linked alerts "+strings.Join(ids, ", "), actorName)
+		auditIncident(r, "incident.link_alerts", inc, "alerts="+strings.Join(ids, ","))
+		if inc, err = serverStore.GetIncident(ctx, inc.ID); err != nil || inc == nil {
+			http.Error(w, "failed to load incident", http.StatusInternalServerError)
+			return
+		}
+		broadcastIncident("updated", inc)
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(inc)
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// updateIncident applies req to inc and records status and assignment
+// changes in the incident's history.
+func updateIncident(w http.ResponseWriter, r *http.Request, inc *storage.Incident, req incidentUpdateRequest) {
+	ctx := r.Context()
+	prevStatus, prevAssignee := inc.Status, inc.Assignee
+	if req.Title != nil {
+		inc.Title = *req.Title
+	}
+	if req.Description != nil {
+		inc.Description = *req.Description
+	}
+	if req.Severity != nil {
+		inc.Severity = *req.Severity
+	}
+	if req.Status != nil {
+		inc.Status = *req.Status
+	}
+	if req.Assignee != nil {
+		inc.Assignee = *req.Assignee
+	}
+	if err := inc.Validate(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if len(strings.TrimSpace(req.Note)) > storage.MaxIncidentNoteLength {
+		http.Error(w, fmt.Sprintf("note must be at most %d characters", storage.MaxIncidentNoteLength), http.StatusBadRequest)
+		return
+	}
+	if err := serverStore.UpdateIncident(ctx, inc); err != nil {
+		logError("Failed to update incident", "id", inc.ID, "error", err)
+		http.Error(w, "failed to update incident", http.StatusInternalServerError)
+		return
+	}
+
+	_, _, actorName, _ := auditActorFromPrincipal(r)
+	var changes []string
+	if inc.Status != prevStatus {
+		addIncidentHistory(ctx, inc.ID, storage.IncidentNoteKindStatus,
+			fmt.Sprintf("Status changed from %s to %s", prevStatus, inc.Status), actorName)
+		changes = append(changes, "status="+inc.Status)
+	}
+	if inc.Assignee != prevAssignee {
+		body := "Unassigned"
+		if inc.Assignee != "" {
+			body = "Assigned to " + inc.Assignee
+		}
+		addIncidentHistory(ctx, inc.ID, storage.IncidentNoteKindAssignment, body, actorName)
+		changes = append(changes, "assignee="+inc.Assignee)
+	}
+	if strings.TrimSpace(req.Note) != "" {
+		addIncidentHistory(ctx, inc.ID, storage.IncidentNoteKindNote, req.Note, actorName)
+	}
+	auditIncident(r, "incident.update", inc, strings.Join(changes, " "))
+	broadcastIncident("updated", inc)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(inc)
+}
+
+// checkIncidentAlerts verifies that every alert exists and belongs to the
+// incident's tenant. It writes the error response and returns false when one
+// doesn't.
+func checkIncidentAlerts(ctx context.Context, w http.ResponseWriter, r *http.Request, inc *storage.Incident, alertIDs []int64) bool {
+	scope, _ := tenantScope(getPrincipal(r))
+	agentTenants := make(map[string]string)
+	for _, alertID := range alertIDs {
+		a, err := serverStore.GetAlert(ctx, alertID)
+		if err != nil || a == nil {
+			http.Error(w, fmt.Sprintf("alert %d not found", alertID), http.StatusNotFound)
+			return false
+		}
+		tenantID := a.TenantID
+		if tenantID == "" && a.AgentID != "" {
+			if _, ok := agentTenants[a.AgentID]; !ok {
+				if agent, err := serverStore.GetAgent(ctx, a.AgentID); err == nil && agent != nil {
+					agentTenants[a.AgentID] = agent.TenantID
+				}
+			}
+			tenantID = agentTenants[a.AgentID]
+		}
+		if !tenantAllowed(scope, tenantID) {
+			http.Error(w, fmt.Sprintf("alert %d not found", alertID), http.StatusNotFound)
+			return false
+		}
+		if inc.TenantID != "" && tenantID != "" && tenantID != inc.TenantID {
+			http.Error(w, fmt.Sprintf("alert %d belongs to another tenant", alertID), http.StatusBadRequest)
+			return false
+		}
+	}
+	return true
+}
+
+// addIncidentHistory records a history entry, logging rather than failing
+// the request when it can't be saved.
+func addIncidentHistory(ctx context.Context, incidentID int64, kind, body, author string) {
+	note := &storage.IncidentNote{IncidentID: incidentID, Kind: kind, Body: body, Author: author}
+	if err := serverStore.AddIncidentNote(ctx, note); err != nil {
+		logWarn("Failed to record incident history", "id", incidentID, "kind", kind, "error", err)
+	}
+}
+
+func writeIncidents(w http.ResponseWriter, incidents []*storage.Incident) {
+	if incidents == nil {
+		incidents = []*storage.Incident{}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(incidents)
+}
+
+func auditIncident(r *http.Request, action string, inc *storage.Incident, details string) {
+	actorType, actorID, actorName, actorTenant := auditActorFromPrincipal(r)
+	logInfo("Incident change", "action", action, "id", inc.ID, "actor", actorName)
+	if details == "" {
+		details = fmt.Sprintf("status=%s", inc.Status)
+	}
+	logAuditEntry(r.Context(), &storage.AuditEntry{
+		ActorType:  actorType,
+		ActorID:    actorID,
+		ActorName:  actorName,
+		TenantID:   actorTenant,
+		Action:     action,
+		TargetType: "incident",
+		TargetID:   strconv.FormatInt(inc.ID, 10),
+		Details:    details,
+		IPAddress:  extractClientIP(r),
+		UserAgent:  r.Header.Get("User-Agent"),
+	})
+}
+
+// broadcastIncident tells UI clients an incident was created or changed.
+func broadcastIncident(change string, inc *storage.Incident) {
+	sseHub.Broadcast(SSEEvent{
+		Type: "incident",
+		Data: map[string]interface{}{
+			"change":        change,
+			"id":            inc.ID,
+			"tenant_id":     inc.TenantID,
+			"title":         inc.Title,
+			"status":        inc.Status,
+			"severity":      inc.Severity,
+			"assignee":      inc.Assignee,
+			"device_serial": inc.DeviceSerial,
+			"source":        inc.Source,
+		},
+	})
+}
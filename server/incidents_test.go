@@ -0,0 +1,117 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"printmaster/server/storage"
+)
+
+func TestIncidentWorkflow(t *testing.T) {
+	store := SetupTestStore(t)
+	ctx := context.Background()
+	for _, a := range []*storage.Agent{
+		{AgentID: "agent-a", Hostname: "a", Token: "ta", TenantID: "tenant-a", Status: "active", RegisteredAt: time.Now(), LastSeen: time.Now()},
+		{AgentID: "agent-b", Hostname: "b", Token: "tb", TenantID: "tenant-b", Status: "active", RegisteredAt: time.Now(), LastSeen: time.Now()},
+	} {
+		if err := store.RegisterAgent(ctx, a); err != nil {
+			t.Fatalf("RegisterAgent: %v", err)
+		}
+	}
+	dev := &storage.Device{}
+	dev.Serial, dev.AgentID, dev.LastSeen = "SN-A", "agent-a", time.Now()
+	if err := store.UpsertDevice(ctx, dev); err != nil {
+		t.Fatalf("UpsertDevice: %v", err)
+	}
+	alertA, err := store.CreateAlert(ctx, &storage.Alert{
+		Type: "device_offline", Severity: "warning", Scope: "device", Status: "active",
+		AgentID: "agent-a", DeviceSerial: "SN-A", Title: "Device Offline: SN-A", TriggeredAt: time.Now().Add(-2 * time.Hour),
+	})
+	if err != nil {
+		t.Fatalf("CreateAlert: %v", err)
+	}
+	alertB, err := store.CreateAlert(ctx, &storage.Alert{
+		Type: "device_offline", Severity: "warning", Scope: "device", Status: "active",
+		AgentID: "agent-b", DeviceSerial: "SN-B", Title: "Device Offline: SN-B", TriggeredAt: time.Now(),
+	})
+	if err != nil {
+		t.Fatalf("CreateAlert: %v", err)
+	}
+
+	call := func(user *storage.User, handler http.HandlerFunc, method, path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		rr := httptest.NewRecorder()
+		handler(rr, InjectTestUser(req, user))
+		return rr
+	}
+	operator := NewTestUser(storage.RoleOperator, "tenant-a")
+	viewer := NewTestUser(storage.RoleViewer, "tenant-a")
+
+	if rr := call(viewer, handleIncidents, http.MethodPost, "/api/v1/incidents", `{"title":"x"}`); rr.Code != http.StatusForbidden {
+		t.Fatalf("viewer create: expected 403, got %d", rr.Code)
+	}
+	body := `{"title":"Lobby printer down","serial":"SN-A","alert_ids":[` + strconv.FormatInt(alertB, 10) + `]}`
+	if rr := call(operator, handleIncidents, http.MethodPost, "/api/v1/incidents", body); rr.Code != http.StatusNotFound {
+		t.Fatalf("other tenant's alert: expected 404, got %d", rr.Code)
+	}
+
+	body = `{"title":"Lobby printer down","serial":"SN-A","alert_ids":[` + strconv.FormatInt(alertA, 10) + `]}`
+	rr := call(operator, handleIncidents, http.MethodPost, "/api/v1/incidents", body)
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("create: %d %s", rr.Code, rr.Body.String())
+	}
+	var inc storage.Incident
+	if err := json.Unmarshal(rr.Body.Bytes(), &inc); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if inc.TenantID != "tenant-a" || inc.Status != storage.IncidentStatusOpen || len(inc.AlertIDs) != 1 {
+		t.Fatalf("unexpected incident: %+v", inc)
+	}
+
+	path := "/api/v1/incidents/" + strconv.FormatInt(inc.ID, 10)
+	if rr := call(operator, handleIncident, http.MethodPatch, path, `{"status":"closed"}`); rr.Code != http.StatusBadRequest {
+		t.Fatalf("invalid status: expected 400, got %d", rr.Code)
+	}
+	rr = call(operator, handleIncident, http.MethodPatch, path, `{"status":"waiting_parts","assignee":"bob","note":"Fuser on order"}`)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("update: %d %s", rr.Code, rr.Body.String())
+	}
+	if rr := call(operator, handleIncident, http.MethodPost, path+"/notes", `{"body":"Fuser arrived"}`); rr.Code != http.StatusCreated {
+		t.Fatalf("add note: %d %s", rr.Code, rr.Body.String())
+	}
+
+	rr = call(viewer, handleIncident, http.MethodGet, path, "")
+	var detail struct {
+		storage.Incident
+		Alerts []storage.Alert        `json:"alerts"`
+		Notes  []storage.IncidentNote `json:"notes"`
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &detail); err != nil {
+		t.Fatalf("detail: %d %s", rr.Code, rr.Body.String())
+	}
+	if detail.Status != storage.IncidentStatusWaitingParts || detail.Assignee != "bob" || len(detail.Alerts) != 1 {
+		t.Fatalf("unexpected detail: %+v", detail.Incident)
+	}
+	kinds := make([]string, len(detail.Notes))
+	for i, n := range detail.Notes {
+		kinds[i] = n.Kind
+	}
+	if strings.Join(kinds, ",") != "status_change,assignment,note,note" {
+		t.Fatalf("unexpected history: %v", kinds)
+	}
+
+	if rr := call(NewTestUser(storage.RoleOperator, "tenant-b"), handleIncident, http.MethodGet, path, ""); rr.Code != http.StatusNotFound {
+		t.Fatalf("other tenant: expected 404, got %d", rr.Code)
+	}
+	rr = call(viewer, handleIncidents, http.MethodGet, "/api/v1/incidents?status=waiting_parts", "")
+	var list []storage.Incident
+	if err := json.Unmarshal(rr.Body.Bytes(), &list); err != nil || len(list) != 1 {
+		t.Fatalf("list: %d %s", rr.Code, rr.Body.String())
+	}
+}
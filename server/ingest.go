@@ -0,0 +1,59 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	authz "printmaster/server/authz"
+	"printmaster/server/ingest"
+)
+
+// ingestQueueSet holds the worker pools for agent uploads, one per kind so a
+// burst of device uploads cannot starve metrics or meter reads.
+type ingestQueueSet struct {
+	devices    *ingest.Queue
+	metrics    *ingest.Queue
+	meterReads *ingest.Queue
+}
+
+var activeIngestQueues *ingestQueueSet
+
+func setupIngestQueues(cfg *Config) *ingestQueueSet {
+	qcfg := ingest.Config{}
+	if cfg != nil {
+		qcfg.Workers = cfg.Ingest.Workers
+		qcfg.QueueSize = cfg.Ingest.QueueSize
+		qcfg.MaxWait = time.Duration(cfg.Ingest.MaxWaitSeconds) * time.Second
+	}
+	set := &ingestQueueSet{
+		devices:    ingest.New("devices", qcfg),
+		metrics:    ingest.New("metrics", qcfg),
+		meterReads: ingest.New("meter_reads", qcfg),
+	}
+	activeIngestQueues = set
+	return set
+}
+
+func (s *ingestQueueSet) stats() []ingest.Stats {
+	return []ingest.Stats{s.devices.Stats(), s.metrics.Stats(), s.meterReads.Stats()}
+}
+
+// handleIngestStats reports upload queue depth, rejections and latency.
+func handleIngestStats(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "GET only", http.StatusMethodNotAllowed)
+		return
+	}
+	if !authorizeOrReject(w, r, authz.ActionSettingsServerRead, authz.ResourceRef{}) {
+		return
+	}
+	queues := []ingest.Stats{}
+	if activeIngestQueues != nil {
+		queues = activeIngestQueues.stats()
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"queues": queues,
+	})
+}
@@ -0,0 +1,290 @@
+// Package ingest bounds how many agent uploads the server processes at once.
+// Each upload kind gets a queue with a fixed worker pool; when the queue is
+// full, or a request waits too long for a worker, the client is told to come
+// back later (429 + Retry-After) instead of piling more work onto the store.
+package ingest
+
+import (
+	"fmt"
+	"log/slog"
+	"math/rand/v2"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// latencyWindow is how many recent requests latency percentiles cover.
+const latencyWindow = 512
+
+// Config sizes a queue.
+type Config struct {
+	Workers       int           // Concurrent handlers (default 4)
+	QueueSize     int           // Requests that may wait for a worker (default 100)
+	MaxWait       time.Duration // Longest a request may wait before being rejected (default 30s)
+	MinRetryAfter time.Duration // Lower bound for the Retry-After hint (default 5s)
+	MaxRetryAfter time.Duration // Upper bound for the Retry-After hint (default 5m)
+	Logger        *slog.Logger
+}
+
+func (c Config) withDefaults() Config {
+	if c.Workers <= 0 {
+		c.Workers = 4
+	}
+	if c.QueueSize <= 0 {
+		c.QueueSize = 100
+	}
+	if c.MaxWait <= 0 {
+		c.MaxWait = 30 * time.Second
+	}
+	if c.MinRetryAfter <= 0 {
+		c.MinRetryAfter = 5 * time.Second
+	}
+	if c.MaxRetryAfter < c.MinRetryAfter {
+		c.MaxRetryAfter = 5 * time.Minute
+		if c.MaxRetryAfter < c.MinRetryAfter {
+			c.MaxRetryAfter = c.MinRetryAfter
+		}
+	}
+	return c
+}
+
+// Job states. A queued job is claimed exactly once: by a worker (running) or
+// by the waiting request giving up (abandoned).
+const (
+	jobQueued int32 = iota
+	jobRunning
+	jobAbandoned
+)
+
+type job struct {
+	w        http.ResponseWriter
+	r        *http.Request
+	next     http.HandlerFunc
+	enqueued time.Time
+	state    atomic.Int32
+	done     chan struct{}
+}
+
+// Queue runs wrapped handlers on a bounded worker pool.
+type Queue struct {
+	name   string
+	cfg    Config
+	logger *slog.Logger
+	jobs   chan *job
+	stopCh chan struct{}
+	wg     sync.WaitGroup
+
+	inFlight  atomic.Int64
+	accepted  atomic.Int64
+	rejected  atomic.Int64
+	expired   atomic.Int64
+	processed atomic.Int64
+
+	mu         sync.Mutex
+	waits      [latencyWindow]time.Duration
+	durations  [latencyWindow]time.Duration
+	samples    int
+	nextSample int
+	lastReject time.Time
+}
+
+// Stats is a point-in-time view of a queue.
+type Stats struct {
+	Name           string    `json:"name"`
+	Workers        int       `json:"workers"`
+	QueueCapacity  int       `json:"queue_capacity"`
+	QueueDepth     int       `json:"queue_depth"`
+	InFlight       int64     `json:"in_flight"`
+	Accepted       int64     `json:"accepted"`
+	Rejected       int64     `json:"rejected"` // Turned away because the queue was full
+	Expired        int64     `json:"expired"`  // Gave up waiting for a worker
+	Processed      int64     `json:"processed"`
+	WaitP50Ms      float64   `json:"wait_p50_ms"` // Time spent queued
+	WaitP95Ms      float64   `json:"wait_p95_ms"`
+	ProcessP50Ms   float64   `json:"process_p50_ms"` // Time spent in the handler
+	ProcessP95Ms   float64   `json:"process_p95_ms"`
+	ProcessMaxMs   float64   `json:"process_max_ms"`
+	LastRejectedAt time.Time `json:"last_rejected_at,omitempty"`
+}
+
+// New starts a queue and its workers. name identifies it in stats and logs.
+func New(name string, cfg Config) *Queue {
+	cfg = cfg.withDefaults()
+	logger := cfg.Logger
+	if logger == nil {
+		logger = slog.Default()
+	}
+	q := &Queue{
+		name:   name,
+		cfg:    cfg,
+		logger: logger,
+		jobs:   make(chan *job, cfg.QueueSize),
+		stopCh: make(chan struct{}),
+	}
+	for i := 0; i < cfg.Workers; i++ {
+		q.wg.Add(1)
+		go q.worker()
+	}
+	return q
+}
+
+// Close stops the workers. Requests still queued are answered with 503.
+func (q *Queue) Close() {
+	close(q.stopCh)
+	q.wg.Wait()
+	for {
+		select {
+		case j := <-q.jobs:
+			if j.state.CompareAndSwap(jobQueued, jobRunning) {
+				http.Error(j.w, "server shutting down", http.StatusServiceUnavailable)
+				close(j.done)
+			}
+		default:
+			return
+		}
+	}
+}
+
+// Wrap returns a handler that runs next on the queue's workers. The calling
+// request blocks until its job has finished, so next writes the response as
+// usual.
+func (q *Queue) Wrap(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		j := &job{w: w, r: r, next: next, enqueued: time.Now(), done: make(chan struct{})}
+		select {
+		case q.jobs <- j:
+			q.accepted.Add(1)
+		default:
+			q.rejected.Add(1)
+			q.reject(w, "ingest queue full")
+			return
+		}
+
+		timer := time.NewTimer(q.cfg.MaxWait)
+		defer timer.Stop()
+		select {
+		case <-j.done:
+			return
+		case <-timer.C:
+			if j.state.CompareAndSwap(jobQueued, jobAbandoned) {
+				q.expired.Add(1)
+				q.reject(w, "timed out waiting for an ingest worker")
+				return
+			}
+		case <-r.Context().Done():
+			if j.state.CompareAndSwap(jobQueued, jobAbandoned) {
+				q.expired.Add(1)
+				return
+			}
+		}
+		// A worker already picked the job up; it owns the response now.
+		<-j.done
+	}
+}
+
+func (q *Queue) worker() {
+	defer q.wg.Done()
+	for {
+		select {
+		case <-q.stopCh:
+			return
+		case j := <-q.jobs:
+			q.run(j)
+		}
+	}
+}
+
+func (q *Queue) run(j *job) {
+	if !j.state.CompareAndSwap(jobQueued, jobRunning) {
+		return // Abandoned while queued
+	}
+	defer close(j.done)
+	start := time.Now()
+	q.inFlight.Add(1)
+	defer func() {
+		q.inFlight.Add(-1)
+		if rec := recover(); rec != nil {
+			q.logger.Error("Ingest handler panicked", "queue", q.name, "path", j.r.URL.Path, "panic", fmt.Sprint(rec))
+			http.Error(j.w, "internal error", http.StatusInternalServerError)
+		}
+		q.processed.Add(1)
+		q.record(start.Sub(j.enqueued), time.Since(start))
+	}()
+	j.next(j.w, j.r)
+}
+
+func (q *Queue) record(wait, duration time.Duration) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.waits[q.nextSample] = wait
+	q.durations[q.nextSample] = duration
+	q.nextSample = (q.nextSample + 1) % latencyWindow
+	if q.samples < latencyWindow {
+		q.samples++
+	}
+}
+
+// reject answers 429 with a Retry-After hint sized to the current backlog,
+// jittered so rejected agents do not all come back at the same moment.
+func (q *Queue) reject(w http.ResponseWriter, reason string) {
+	retry := q.retryAfter()
+	q.mu.Lock()
+	q.lastReject = time.Now()
+	q.mu.Unlock()
+	q.logger.Warn("Ingest request rejected", "queue", q.name, "reason", reason, "depth", len(q.jobs), "retry_after", retry)
+	w.Header().Set("Retry-After", strconv.Itoa(int(retry/time.Second)))
+	http.Error(w, reason+", retry later", http.StatusTooManyRequests)
+}
+
+func (q *Queue) retryAfter() time.Duration {
+	q.mu.Lock()
+	p50 := percentile(append([]time.Duration(nil), q.durations[:q.samples]...), 0.5)
+	q.mu.Unlock()
+	// Time for the workers to drain what is queued now
+	estimate := time.Duration(len(q.jobs)+1) * p50 / time.Duration(q.cfg.Workers)
+	estimate += rand.N(estimate/2 + time.Second)
+	return min(max(estimate, q.cfg.MinRetryAfter), q.cfg.MaxRetryAfter).Round(time.Second)
+}
+
+// Stats reports queue depth, counters and recent latency percentiles.
+func (q *Queue) Stats() Stats {
+	q.mu.Lock()
+	waits := append([]time.Duration(nil), q.waits[:q.samples]...)
+	durations := append([]time.Duration(nil), q.durations[:q.samples]...)
+	lastReject := q.lastReject
+	q.mu.Unlock()
+	return Stats{
+		Name:           q.name,
+		Workers:        q.cfg.Workers,
+		QueueCapacity:  q.cfg.QueueSize,
+		QueueDepth:     len(q.jobs),
+		InFlight:       q.inFlight.Load(),
+		Accepted:       q.accepted.Load(),
+		Rejected:       q.rejected.Load(),
+		Expired:        q.expired.Load(),
+		Processed:      q.processed.Load(),
+		WaitP50Ms:      millis(percentile(waits, 0.5)),
+		WaitP95Ms:      millis(percentile(waits, 0.95)),
+		ProcessP50Ms:   millis(percentile(durations, 0.5)),
+		ProcessP95Ms:   millis(percentile(durations, 0.95)),
+		ProcessMaxMs:   millis(percentile(durations, 1)),
+		LastRejectedAt: lastReject,
+	}
+}
+
+// percentile returns the p-th percentile of samples (sorting them in place).
+func percentile(samples []time.Duration, p float64) time.Duration {
+	if len(samples) == 0 {
+		return 0
+	}
+	sort.Slice(samples, func(i, j int) bool { return samples[i] < samples[j] })
+	idx := int(p*float64(len(samples)-1) + 0.5)
+	return samples[idx]
+}
+
+func millis(d time.Duration) float64 {
+	return float64(d.Microseconds()) / 1000
+}
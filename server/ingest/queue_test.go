@@ -0,0 +1,156 @@
+package ingest
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestQueueRunsHandlerAndRecordsStats(t *testing.T) {
+	t.Parallel()
+	q := New("devices", Config{Workers: 2, QueueSize: 4})
+	defer q.Close()
+
+	h := q.Wrap(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusAccepted)
+	})
+	for i := 0; i < 3; i++ {
+		rr := httptest.NewRecorder()
+		h(rr, httptest.NewRequest(http.MethodPost, "/api/v1/devices/batch", nil))
+		if rr.Code != http.StatusAccepted {
+			t.Fatalf("expected handler status, got %d", rr.Code)
+		}
+	}
+
+	stats := q.Stats()
+	if stats.Accepted != 3 || stats.Processed != 3 || stats.Rejected != 0 || stats.QueueDepth != 0 || stats.InFlight != 0 {
+		t.Fatalf("unexpected stats: %+v", stats)
+	}
+	if stats.Name != "devices" || stats.Workers != 2 || stats.QueueCapacity != 4 {
+		t.Fatalf("unexpected config in stats: %+v", stats)
+	}
+}
+
+func TestQueueRejectsWhenFull(t *testing.T) {
+	t.Parallel()
+	q := New("metrics", Config{Workers: 1, QueueSize: 1, MinRetryAfter: 7 * time.Second})
+	defer q.Close()
+
+	release := make(chan struct{})
+	started := make(chan struct{}, 2)
+	h := q.Wrap(func(w http.ResponseWriter, r *http.Request) {
+		started <- struct{}{}
+		<-release
+		w.WriteHeader(http.StatusOK)
+	})
+
+	var wg sync.WaitGroup
+	codes := make([]int, 2)
+	send := func(i int) {
+		defer wg.Done()
+		rr := httptest.NewRecorder()
+		h(rr, httptest.NewRequest(http.MethodPost, "/api/v1/metrics/batch", nil))
+		codes[i] = rr.Code
+	}
+
+	// First request occupies the only worker, second fills the queue
+	wg.Add(1)
+	go send(0)
+	<-started
+	wg.Add(1)
+	go send(1)
+	waitFor(t, func() bool { return q.Stats().QueueDepth == 1 })
+
+	rr := httptest.NewRecorder()
+	h(rr, httptest.NewRequest(http.MethodPost, "/api/v1/metrics/batch", nil))
+	if rr.Code != http.StatusTooManyRequests {
+		t.Fatalf("expected 429 when queue is full, got %d", rr.Code)
+	}
+	retry, err := strconv.Atoi(rr.Header().Get("Retry-After"))
+	if err != nil || retry < 7 {
+		t.Fatalf("Retry-After = %q, want >= 7", rr.Header().Get("Retry-After"))
+	}
+
+	close(release)
+	wg.Wait()
+	if codes[0] != http.StatusOK || codes[1] != http.StatusOK {
+		t.Fatalf("queued requests should complete, got %v", codes)
+	}
+	stats := q.Stats()
+	if stats.Rejected != 1 || stats.Processed != 2 || stats.LastRejectedAt.IsZero() {
+		t.Fatalf("unexpected stats: %+v", stats)
+	}
+}
+
+func TestQueueExpiresRequestsWaitingTooLong(t *testing.T) {
+	t.Parallel()
+	q := New("meter_reads", Config{Workers: 1, QueueSize: 2, MaxWait: 50 * time.Millisecond})
+	defer q.Close()
+
+	release := make(chan struct{})
+	started := make(chan struct{}, 1)
+	ran := make(chan struct{}, 2)
+	h := q.Wrap(func(w http.ResponseWriter, r *http.Request) {
+		ran <- struct{}{}
+		if r.URL.Path == "/slow" {
+			started <- struct{}{}
+			<-release
+		}
+	})
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		h(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/slow", nil))
+	}()
+	<-started
+
+	rr := httptest.NewRecorder()
+	h(rr, httptest.NewRequest(http.MethodPost, "/queued", nil))
+	if rr.Code != http.StatusTooManyRequests || rr.Header().Get("Retry-After") == "" {
+		t.Fatalf("expected 429 with Retry-After after waiting, got %d", rr.Code)
+	}
+
+	close(release)
+	<-done
+	// The abandoned job must not run once a worker frees up
+	waitFor(t, func() bool { return q.Stats().QueueDepth == 0 })
+	if len(ran) != 1 {
+		t.Fatalf("abandoned job ran: %d handler calls", len(ran))
+	}
+	if stats := q.Stats(); stats.Expired != 1 || stats.Processed != 1 {
+		t.Fatalf("unexpected stats: %+v", stats)
+	}
+}
+
+func TestQueueRecoversHandlerPanic(t *testing.T) {
+	t.Parallel()
+	q := New("devices", Config{Workers: 1})
+	defer q.Close()
+
+	rr := httptest.NewRecorder()
+	q.Wrap(func(http.ResponseWriter, *http.Request) { panic("boom") })(rr, httptest.NewRequest(http.MethodPost, "/", nil))
+	if rr.Code != http.StatusInternalServerError {
+		t.Fatalf("expected 500 after panic, got %d", rr.Code)
+	}
+	// The worker survives the panic
+	rr = httptest.NewRecorder()
+	q.Wrap(func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusNoContent) })(rr, httptest.NewRequest(http.MethodPost, "/", nil))
+	if rr.Code != http.StatusNoContent {
+		t.Fatalf("expected worker to keep running, got %d", rr.Code)
+	}
+}
+
+func waitFor(t *testing.T, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal("condition not met in time")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
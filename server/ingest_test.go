@@ -0,0 +1,48 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"printmaster/server/ingest"
+	"printmaster/server/storage"
+)
+
+func TestHandleIngestStats(t *testing.T) {
+	queues := setupIngestQueues(&Config{Ingest: IngestConfig{Workers: 2, QueueSize: 10, MaxWaitSeconds: 5}})
+	t.Cleanup(func() {
+		queues.devices.Close()
+		queues.metrics.Close()
+		queues.meterReads.Close()
+		activeIngestQueues = nil
+	})
+
+	rr := httptest.NewRecorder()
+	queues.devices.Wrap(func(w http.ResponseWriter, r *http.Request) {})(rr, httptest.NewRequest(http.MethodPost, "/api/v1/devices/batch", nil))
+
+	req := InjectTestAdmin(httptest.NewRequest(http.MethodGet, "/api/v1/ingest/stats", nil))
+	rr = httptest.NewRecorder()
+	handleIngestStats(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	var resp struct {
+		Queues []ingest.Stats `json:"queues"`
+	}
+	if err := json.NewDecoder(rr.Body).Decode(&resp); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if len(resp.Queues) != 3 || resp.Queues[0].Name != "devices" || resp.Queues[0].Processed != 1 || resp.Queues[0].Workers != 2 {
+		t.Fatalf("unexpected stats: %+v", resp.Queues)
+	}
+
+	// Server internals are not visible to tenant users
+	req = InjectTestUser(httptest.NewRequest(http.MethodGet, "/api/v1/ingest/stats", nil), NewTestUser(storage.RoleViewer, "tenant-a"))
+	rr = httptest.NewRecorder()
+	handleIngestStats(rr, req)
+	if rr.Code != http.StatusForbidden {
+		t.Fatalf("expected 403 for viewer, got %d", rr.Code)
+	}
+}
@@ -0,0 +1,144 @@
+// Package pdf writes minimal PDF 1.4 documents using the standard Helvetica
+// font, for label sheets and report exports.
+package pdf
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Writer assembles a minimal PDF 1.4 document: a page tree of pages that
+// share the standard Helvetica font (resource /F1). Standard fonts need no
+// embedding, which keeps files small and the writer free of font handling.
+type Writer struct {
+	objects [][]byte // objects[i] is object number i+1
+	pages   []int
+}
+
+const (
+	pdfCatalogObj = 1
+	pdfPagesObj   = 2
+	pdfFontObj    = 3
+)
+
+// NewWriter returns an empty document.
+func NewWriter() *Writer {
+	p := &Writer{objects: make([][]byte, 3)}
+	p.objects[pdfCatalogObj-1] = []byte(fmt.Sprintf("<< /Type /Catalog /Pages %d 0 R >>", pdfPagesObj))
+	p.objects[pdfFontObj-1] = []byte("<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica /Encoding /WinAnsiEncoding >>")
+	return p
+}
+
+func (p *Writer) add(body []byte) int {
+	p.objects = append(p.objects, body)
+	return len(p.objects)
+}
+
+// AddPage adds a page of the given size in points drawn by content.
+func (p *Writer) AddPage(width, height float64, content []byte) {
+	stream := p.add([]byte(fmt.Sprintf("<< /Length %d >>\nstream\n%s\nendstream", len(content), content)))
+	page := p.add([]byte(fmt.Sprintf(
+		"<< /Type /Page /Parent %d 0 R /MediaBox [0 0 %s %s] /Resources << /Font << /F1 %d 0 R >> >> /Contents %d 0 R >>",
+		pdfPagesObj, Num(width), Num(height), pdfFontObj, stream)))
+	p.pages = append(p.pages, page)
+}
+
+// Render writes the document to w.
+func (p *Writer) Render(w io.Writer) error {
+	kids := make([]string, len(p.pages))
+	for i, id := range p.pages {
+		kids[i] = fmt.Sprintf("%d 0 R", id)
+	}
+	p.objects[pdfPagesObj-1] = []byte(fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), len(p.pages)))
+
+	var buf bytes.Buffer
+	// The binary comment marks the file as binary for transfer tools
+	buf.WriteString("%PDF-1.4\n%\xe2\xe3\xcf\xd3\n")
+	offsets := make([]int, len(p.objects))
+	for i, body := range p.objects {
+		offsets[i] = buf.Len()
+		fmt.Fprintf(&buf, "%d 0 obj\n%s\nendobj\n", i+1, body)
+	}
+	xref := buf.Len()
+	fmt.Fprintf(&buf, "xref\n0 %d\n0000000000 65535 f \n", len(p.objects)+1)
+	for _, off := range offsets {
+		fmt.Fprintf(&buf, "%010d 00000 n \n", off)
+	}
+	fmt.Fprintf(&buf, "trailer\n<< /Size %d /Root %d 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(p.objects)+1, pdfCatalogObj, xref)
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+// Num formats a coordinate with at most two decimals.
+func Num(v float64) string {
+	return strconv.FormatFloat(math.Round(v*100)/100, 'f', -1, 64)
+}
+
+// String encodes s as a literal string in WinAnsiEncoding. Characters the
+// encoding cannot show become '?'.
+func String(s string) string {
+	var b strings.Builder
+	b.WriteByte('(')
+	for _, r := range s {
+		switch {
+		case r == '(' || r == ')' || r == '\\':
+			b.WriteByte('\\')
+			b.WriteRune(r)
+		case r >= 0x20 && r < 0x7f:
+			b.WriteRune(r)
+		case r >= 0xa0 && r <= 0xff:
+			// The Latin-1 supplement has the same codes in WinAnsi
+			fmt.Fprintf(&b, "\\%03o", r)
+		default:
+			b.WriteByte('?')
+		}
+	}
+	b.WriteByte(')')
+	return b.String()
+}
+
+// helveticaWidths holds the Helvetica advance widths for ASCII 32-126 in
+// thousandths of the font size.
+var helveticaWidths = [95]int{
+	278, 278, 355, 556, 556, 889, 667, 191, 333, 333, 389, 584, 278, 333, 278, 278, // space - /
+	556, 556, 556, 556, 556, 556, 556, 556, 556, 556, // 0 - 9
+	278, 278, 584, 584, 584, 556, 1015, // : - @
+	667, 667, 722, 722, 667, 611, 778, 722, 278, 500, 667, 556, 833, // A - M
+	722, 778, 667, 778, 722, 667, 611, 722, 667, 944, 667, 667, 611, // N - Z
+	278, 278, 278, 469, 556, 333, // [ - `
+	556, 556, 500, 556, 556, 278, 556, 556, 222, 222, 500, 222, 833, // a - m
+	556, 556, 556, 556, 333, 500, 278, 556, 500, 722, 500, 500, 500, // n - z
+	334, 260, 334, 584, // { - ~
+}
+
+// TextWidth returns the width of s in points at the given font size.
+func TextWidth(s string, size float64) float64 {
+	total := 0
+	for _, r := range s {
+		if r >= 32 && r <= 126 {
+			total += helveticaWidths[r-32]
+		} else {
+			total += 556
+		}
+	}
+	return float64(total) * size / 1000
+}
+
+// FitText shortens s with an ellipsis until it fits in width points.
+func FitText(s string, size, width float64) string {
+	if TextWidth(s, size) <= width {
+		return s
+	}
+	runes := []rune(s)
+	for len(runes) > 0 {
+		runes = runes[:len(runes)-1]
+		if t := strings.TrimRight(string(runes), " ") + "..."; TextWidth(t, size) <= width {
+			return t
+		}
+	}
+	return ""
+}
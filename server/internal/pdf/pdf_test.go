@@ -0,0 +1,21 @@
+package pdf
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestTextHelpers(t *testing.T) {
+	t.Parallel()
+	if got := String(`a(b)\ é €`); got != `(a\(b\)\\ \351 ?)` {
+		t.Errorf("String = %s", got)
+	}
+	long := "Konica Minolta bizhub C458 with a very long model suffix"
+	fit := FitText(long, 8, 80)
+	if !strings.HasSuffix(fit, "...") || TextWidth(fit, 8) > 80 {
+		t.Errorf("FitText = %q (%.1fpt)", fit, TextWidth(fit, 8))
+	}
+	if got := FitText("short", 8, 80); got != "short" {
+		t.Errorf("FitText shortened text that fits: %q", got)
+	}
+}
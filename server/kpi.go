@@ -0,0 +1,247 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	authz "printmaster/server/authz"
+	metricsapi "printmaster/server/metrics"
+	"printmaster/server/storage"
+)
+
+const (
+	// kpiCacheTTL bounds how often the KPI summary hits the database; NOC
+	// wallboards polling every few seconds are served from the cache.
+	kpiCacheTTL = 15 * time.Second
+)
+
+// FleetKPI is the compact fleet summary returned by /api/v1/kpi.
+type FleetKPI struct {
+	GeneratedAt time.Time `json:"generated_at"`
+	Devices     struct {
+		Total   int `json:"total"`
+		Online  int `json:"online"`
+		Offline int `json:"offline"`
+	} `json:"devices"`
+	// Alerts that are active or acknowledged, by severity
+	Alerts struct {
+		Total        int `json:"total"`
+		Critical     int `json:"critical"`
+		Warning      int `json:"warning"`
+		Info         int `json:"info"`
+		Acknowledged int `json:"acknowledged"`
+	} `json:"open_alerts"`
+	PagesToday int64 `json:"pages_today"`
+	// TonerRisk counts devices whose lowest supply is in the low or critical band
+	TonerRisk int `json:"toner_risk"`
+	Agents    struct {
+		Total        int `json:"total"`
+		Connected    int `json:"connected"`
+		Disconnected int `json:"disconnected"`
+	} `json:"agents"`
+}
+
+type kpiCacheEntry struct {
+	mu        sync.Mutex // Serializes computation so concurrent polls share one
+	value     *FleetKPI
+	expiresAt time.Time
+}
+
+var (
+	kpiCacheMu sync.Mutex
+	kpiCache   = map[string]*kpiCacheEntry{}
+)
+
+// handleFleetKPI serves GET /api/v1/kpi[?tenant_id=][&tz=].
+//
+// Counts are scoped to the caller's tenants and cached for kpiCacheTTL per
+// scope; "pages today" starts at midnight in tz (default UTC).
+func handleFleetKPI(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "GET only", http.StatusMethodNotAllowed)
+		return
+	}
+	if !authorizeOrReject(w, r, authz.ActionMetricsSummaryRead, authz.ResourceRef{}) {
+		return
+	}
+	principal := getPrincipal(r)
+	if principal == nil {
+		http.Error(w, "unauthenticated", http.StatusUnauthorized)
+		return
+	}
+	scope, ok := tenantScope(principal)
+	if !ok {
+		http.Error(w, "forbidden", http.StatusForbidden)
+		return
+	}
+
+	var tenantIDs []string
+	if tenantID := strings.TrimSpace(r.URL.Query().Get("tenant_id")); tenantID != "" {
+		if !tenantAllowed(scope, tenantID) {
+			http.Error(w, "forbidden", http.StatusForbidden)
+			return
+		}
+		tenantIDs = []string{tenantID}
+	} else if scope != nil {
+		for id := range scope {
+			tenantIDs = append(tenantIDs, id)
+		}
+		sort.Strings(tenantIDs)
+	}
+	loc := time.UTC
+	if tz := strings.TrimSpace(r.URL.Query().Get("tz")); tz != "" {
+		l, err := time.LoadLocation(tz)
+		if err != nil {
+			http.Error(w, "invalid tz", http.StatusBadRequest)
+			return
+		}
+		loc = l
+	}
+
+	kpi, err := cachedFleetKPI(r.Context(), tenantIDs, loc)
+	if err != nil {
+		logError("Failed to build fleet KPIs", "error", err)
+		http.Error(w, "failed to build fleet KPIs", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", fmt.Sprintf("private, max-age=%d", int(kpiCacheTTL.Seconds())))
+	json.NewEncoder(w).Encode(kpi)
+}
+
+// cachedFleetKPI returns the cached KPIs for the tenant set, rebuilding them
+// once they are older than kpiCacheTTL.
+func cachedFleetKPI(ctx context.Context, tenantIDs []string, loc *time.Location) (*FleetKPI, error) {
+	key := strings.Join(tenantIDs, ",") + "|" + loc.String()
+	kpiCacheMu.Lock()
+	entry, ok := kpiCache[key]
+	if !ok {
+		pruneKPICacheLocked()
+		entry = &kpiCacheEntry{}
+		kpiCache[key] = entry
+	}
+	kpiCacheMu.Unlock()
+
+	entry.mu.Lock()
+	defer entry.mu.Unlock()
+	now := time.Now()
+	if entry.value != nil && now.Before(entry.expiresAt) {
+		return entry.value, nil
+	}
+	kpi, err := buildFleetKPI(ctx, tenantIDs, loc)
+	if err != nil {
+		return nil, err
+	}
+	entry.value = kpi
+	entry.expiresAt = now.Add(kpiCacheTTL)
+	return kpi, nil
+}
+
+// pruneKPICacheLocked drops scopes nobody has polled for a while. Entries
+// being rebuilt are skipped.
+func pruneKPICacheLocked() {
+	cutoff := time.Now().Add(-time.Minute)
+	for key, entry := range kpiCache {
+		if !entry.mu.TryLock() {
+			continue
+		}
+		if entry.expiresAt.Before(cutoff) {
+			delete(kpiCache, key)
+		}
+		entry.mu.Unlock()
+	}
+}
+
+// buildFleetKPI computes the KPIs for the given tenants (all when empty).
+func buildFleetKPI(ctx context.Context, tenantIDs []string, loc *time.Location) (*FleetKPI, error) {
+	now := time.Now()
+	kpi := &FleetKPI{GeneratedAt: now.UTC()}
+	inScope := func(tenantID string) bool {
+		return len(tenantIDs) == 0 || sortedContains(tenantIDs, tenantID)
+	}
+
+	agents, err := serverStore.ListAgents(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("list agents: %w", err)
+	}
+	agentIDs := make(map[string]struct{}, len(agents))
+	for _, a := range agents {
+		if a == nil || !inScope(a.TenantID) {
+			continue
+		}
+		agentIDs[a.AgentID] = struct{}{}
+		kpi.Agents.Total++
+		if deriveAgentConnectionType(a) == "none" {
+			kpi.Agents.Disconnected++
+		} else {
+			kpi.Agents.Connected++
+		}
+	}
+
+	devices, err := serverStore.ListAllDevices(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("list devices: %w", err)
+	}
+	for _, d := range filterApprovedDevices(ctx, serverStore, devices) {
+		if d == nil {
+			continue
+		}
+		if _, ok := agentIDs[d.AgentID]; !ok {
+			continue
+		}
+		kpi.Devices.Total++
+		if now.Sub(d.LastSeen) >= metricsapi.OfflineAfter {
+			kpi.Devices.Offline++
+		} else {
+			kpi.Devices.Online++
+		}
+	}
+
+	alerts, err := serverStore.ListActiveAlerts(ctx, storage.AlertFilters{TopLevel: true})
+	if err != nil {
+		return nil, fmt.Errorf("list alerts: %w", err)
+	}
+	for _, a := range alerts {
+		if !inScope(a.TenantID) {
+			continue
+		}
+		kpi.Alerts.Total++
+		if a.Status == string(storage.AlertStatusAcknowledged) {
+			kpi.Alerts.Acknowledged++
+		}
+		switch a.Severity {
+		case string(storage.AlertSeverityCritical):
+			kpi.Alerts.Critical++
+		case string(storage.AlertSeverityWarning):
+			kpi.Alerts.Warning++
+		case string(storage.AlertSeverityInfo):
+			kpi.Alerts.Info++
+		}
+	}
+
+	if kpi.Devices.Total > 0 {
+		local := now.In(loc)
+		midnight := time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, loc)
+		agg, err := serverStore.GetAggregatedMetrics(ctx, midnight, tenantIDs)
+		if err != nil {
+			return nil, fmt.Errorf("aggregate metrics: %w", err)
+		}
+		for _, point := range agg.Fleet.History.TotalImpressions {
+			kpi.PagesToday += point.Value
+		}
+		kpi.TonerRisk = agg.Fleet.Consumables.Critical + agg.Fleet.Consumables.Low
+	}
+	return kpi, nil
+}
+
+// sortedContains reports whether the sorted list holds s.
+func sortedContains(list []string, s string) bool {
+	i := sort.SearchStrings(list, s)
+	return i < len(list) && list[i] == s
+}
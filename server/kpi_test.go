@@ -0,0 +1,128 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"printmaster/server/storage"
+)
+
+func TestHandleFleetKPI(t *testing.T) {
+	store := SetupTestStore(t)
+	ctx := context.Background()
+	kpiCacheMu.Lock()
+	kpiCache = map[string]*kpiCacheEntry{}
+	kpiCacheMu.Unlock()
+
+	now := time.Now()
+	for _, a := range []*storage.Agent{
+		{AgentID: "agent-a", Hostname: "a", Token: "ta", TenantID: "tenant-a", Status: "active", RegisteredAt: now, LastSeen: now},
+		{AgentID: "agent-b", Hostname: "b", Token: "tb", TenantID: "tenant-b", Status: "active", RegisteredAt: now, LastSeen: now.Add(-time.Hour)},
+	} {
+		if err := store.RegisterAgent(ctx, a); err != nil {
+			t.Fatalf("RegisterAgent: %v", err)
+		}
+	}
+	for _, d := range []struct {
+		serial, agentID string
+		lastSeen        time.Time
+	}{
+		{"SN-A1", "agent-a", now},
+		{"SN-A2", "agent-a", now.Add(-time.Hour)},
+		{"SN-B1", "agent-b", now},
+		{"SN-A4", "agent-a", now.Add(-12 * time.Minute)},
+		{"SN-P1", "agent-a", now},
+	} {
+		dev := &storage.Device{}
+		dev.Serial = d.serial
+		dev.AgentID = d.agentID
+		dev.LastSeen = d.lastSeen
+		if err := store.UpsertDevice(ctx, dev); err != nil {
+			t.Fatalf("UpsertDevice: %v", err)
+		}
+	}
+	// Devices pending approval are left out of the summary
+	if err := store.CreateDeviceApproval(ctx, &storage.DeviceApproval{Serial: "SN-P1", TenantID: "tenant-a", AgentID: "agent-a"}); err != nil {
+		t.Fatalf("CreateDeviceApproval: %v", err)
+	}
+	midnight := time.Date(now.UTC().Year(), now.UTC().Month(), now.UTC().Day(), 0, 0, 0, 0, time.UTC)
+	for _, m := range []*storage.MetricsSnapshot{
+		{Serial: "SN-A1", AgentID: "agent-a", Timestamp: midnight.Add(-time.Hour), PageCount: 1000},
+		{Serial: "SN-A1", AgentID: "agent-a", Timestamp: midnight.Add(time.Second), PageCount: 1040,
+			TonerLevels: map[string]interface{}{"black": 3}},
+	} {
+		if err := store.SaveMetrics(ctx, m); err != nil {
+			t.Fatalf("SaveMetrics: %v", err)
+		}
+	}
+	for _, a := range []storage.Alert{
+		{Severity: "critical", TenantID: "tenant-a", Status: string(storage.AlertStatusActive)},
+		{Severity: "warning", TenantID: "tenant-a", Status: string(storage.AlertStatusAcknowledged)},
+		{Severity: "warning", TenantID: "tenant-b", Status: string(storage.AlertStatusActive)},
+	} {
+		a.Type, a.Scope, a.Title, a.TriggeredAt = "device_error", "device", "Error", now
+		if _, err := store.CreateAlert(ctx, &a); err != nil {
+			t.Fatalf("CreateAlert: %v", err)
+		}
+	}
+
+	get := func(user *storage.User, query string) (*FleetKPI, *httptest.ResponseRecorder) {
+		t.Helper()
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/kpi"+query, nil)
+		rr := httptest.NewRecorder()
+		handleFleetKPI(rr, InjectTestUser(req, user))
+		if rr.Code != http.StatusOK {
+			return nil, rr
+		}
+		var kpi FleetKPI
+		if err := json.Unmarshal(rr.Body.Bytes(), &kpi); err != nil {
+			t.Fatalf("decode: %v", err)
+		}
+		return &kpi, rr
+	}
+
+	all, rr := get(NewTestUser(storage.RoleAdmin), "")
+	if all == nil {
+		t.Fatalf("admin: %d %s", rr.Code, rr.Body.String())
+	}
+	if all.Devices.Total != 4 || all.Devices.Online != 3 || all.Devices.Offline != 1 {
+		t.Errorf("devices: %+v", all.Devices)
+	}
+	if all.Agents.Total != 2 || all.Agents.Disconnected != 1 {
+		t.Errorf("agents: %+v", all.Agents)
+	}
+	if all.Alerts.Total != 3 || all.Alerts.Critical != 1 || all.Alerts.Warning != 2 || all.Alerts.Acknowledged != 1 {
+		t.Errorf("alerts: %+v", all.Alerts)
+	}
+	if all.PagesToday != 40 || all.TonerRisk != 1 {
+		t.Errorf("pages today %d, toner risk %d", all.PagesToday, all.TonerRisk)
+	}
+	if rr.Header().Get("Cache-Control") == "" {
+		t.Error("missing Cache-Control header")
+	}
+
+	scoped, _ := get(NewTestUser(storage.RoleViewer, "tenant-b"), "")
+	if scoped == nil || scoped.Devices.Total != 1 || scoped.Alerts.Total != 1 || scoped.PagesToday != 0 {
+		t.Errorf("tenant-b: %+v", scoped)
+	}
+	if _, rr := get(NewTestUser(storage.RoleViewer, "tenant-b"), "?tenant_id=tenant-a"); rr.Code != http.StatusForbidden {
+		t.Errorf("foreign tenant: expected 403, got %d", rr.Code)
+	}
+	if _, rr := get(NewTestUser(storage.RoleAdmin), "?tz=Mars/Olympus"); rr.Code != http.StatusBadRequest {
+		t.Errorf("bad tz: expected 400, got %d", rr.Code)
+	}
+
+	// Within the TTL the cached summary is served without touching the store
+	dev := &storage.Device{}
+	dev.Serial, dev.AgentID, dev.LastSeen = "SN-A3", "agent-a", now
+	if err := store.UpsertDevice(ctx, dev); err != nil {
+		t.Fatalf("UpsertDevice: %v", err)
+	}
+	if again, _ := get(NewTestUser(storage.RoleAdmin), ""); again == nil || again.Devices.Total != 4 || !again.GeneratedAt.Equal(all.GeneratedAt) {
+		t.Errorf("expected cached KPIs, got %+v", again)
+	}
+}
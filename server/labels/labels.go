@@ -0,0 +1,147 @@
+// Package labels renders printable PDF sheets of device asset labels. Each
+// label carries a QR code and a few lines of text, laid out to match a
+// die-cut label template so sheets can be printed and stuck on hardware.
+package labels
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+
+	"printmaster/common/qrcode"
+	"printmaster/server/internal/pdf"
+)
+
+// Label is the content of one label.
+type Label struct {
+	QR    string   // Text encoded in the QR code, usually a link to the device
+	Title string   // Large first line, e.g. the asset number
+	Lines []string // Smaller lines below the title
+}
+
+// Options control how labels are placed on the sheet.
+type Options struct {
+	// Skip leaves the first labels of the first sheet empty, so partly used
+	// sheets can be fed back into the printer.
+	Skip int
+	// Outline draws a thin border around each label to check alignment on
+	// plain paper.
+	Outline bool
+}
+
+// ErrNoLabels is returned when there is nothing to render.
+var ErrNoLabels = errors.New("labels: no labels to render")
+
+const (
+	padding    = 4.0  // points between the label edge and its content
+	quietZone  = 2    // blank modules around the QR code
+	maxTitle   = 14.0 // largest title font size in points
+	lineFactor = 0.75 // detail line size relative to the title
+)
+
+// Render writes labels as a PDF laid out on template t.
+func Render(w io.Writer, t Template, labels []Label, opts Options) error {
+	if len(labels) == 0 {
+		return ErrNoLabels
+	}
+	if t.PerSheet <= 0 {
+		return fmt.Errorf("labels: template %q has no labels", t.ID)
+	}
+	if opts.Skip < 0 || opts.Skip >= t.PerSheet {
+		return fmt.Errorf("labels: skip must be between 0 and %d", t.PerSheet-1)
+	}
+
+	doc := pdf.NewWriter()
+	var page bytes.Buffer
+	slot := opts.Skip
+	for _, l := range labels {
+		if slot == t.PerSheet {
+			doc.AddPage(t.PageWidth, t.PageHeight, page.Bytes())
+			page = bytes.Buffer{}
+			slot = 0
+		}
+		col, row := slot%t.Columns, slot/t.Columns
+		x := t.Left + float64(col)*t.PitchX
+		top := t.PageHeight - t.Top - float64(row)*t.PitchY
+		if err := drawLabel(&page, t, x, top-t.LabelHeight, l, opts.Outline); err != nil {
+			return err
+		}
+		slot++
+	}
+	doc.AddPage(t.PageWidth, t.PageHeight, page.Bytes())
+	return doc.Render(w)
+}
+
+// drawLabel draws one label whose bottom-left corner is at (x, y): the QR
+// code on the left and the text in the space to its right.
+func drawLabel(b *bytes.Buffer, t Template, x, y float64, l Label, outline bool) error {
+	w, h := t.LabelWidth, t.LabelHeight
+	if outline {
+		fmt.Fprintf(b, "q 0.5 w 0.75 G %s %s %s %s re S Q\n", pdf.Num(x), pdf.Num(y), pdf.Num(w), pdf.Num(h))
+	}
+
+	textX := x + padding
+	if l.QR != "" {
+		code, err := qrcode.Encode(l.QR)
+		if err != nil {
+			return fmt.Errorf("labels: QR code for %q: %w", l.Title, err)
+		}
+		side := min(h-2*padding, w*0.45)
+		module := side / float64(code.Size+2*quietZone)
+		qx := x + padding + module*quietZone
+		qy := y + (h-side)/2 + module*quietZone
+		drawQR(b, code, qx, qy, module)
+		textX = x + padding + side + padding
+	}
+
+	textW := x + w - padding - textX
+	if textW <= 0 {
+		return nil
+	}
+	titleSize := min(maxTitle, h*0.22)
+	lineSize := titleSize * lineFactor
+	baseline := y + h - padding - titleSize
+	b.WriteString("BT 0 g\n")
+	if l.Title != "" {
+		fmt.Fprintf(b, "/F1 %s Tf 1 0 0 1 %s %s Tm %s Tj\n",
+			pdf.Num(titleSize), pdf.Num(textX), pdf.Num(baseline), pdf.String(pdf.FitText(l.Title, titleSize, textW)))
+		baseline -= titleSize * 0.4
+	}
+	for _, line := range l.Lines {
+		baseline -= lineSize * 1.2
+		if baseline < y+padding {
+			break
+		}
+		if line == "" {
+			continue
+		}
+		fmt.Fprintf(b, "/F1 %s Tf 1 0 0 1 %s %s Tm %s Tj\n",
+			pdf.Num(lineSize), pdf.Num(textX), pdf.Num(baseline), pdf.String(pdf.FitText(line, lineSize, textW)))
+	}
+	b.WriteString("ET\n")
+	return nil
+}
+
+// drawQR fills the dark modules of code, merging horizontal runs into one
+// rectangle each to keep the content stream short.
+func drawQR(b *bytes.Buffer, code *qrcode.Code, x, y, module float64) {
+	b.WriteString("0 g\n")
+	for row := 0; row < code.Size; row++ {
+		// PDF y grows upwards; QR row 0 is the top
+		ry := y + float64(code.Size-1-row)*module
+		for col := 0; col < code.Size; {
+			if !code.Dark(col, row) {
+				col++
+				continue
+			}
+			start := col
+			for col < code.Size && code.Dark(col, row) {
+				col++
+			}
+			fmt.Fprintf(b, "%s %s %s %s re\n",
+				pdf.Num(x+float64(start)*module), pdf.Num(ry), pdf.Num(float64(col-start)*module), pdf.Num(module))
+		}
+	}
+	b.WriteString("f\n")
+}
@@ -0,0 +1,71 @@
+package labels
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestRenderPaginatesAndBuildsXref(t *testing.T) {
+	t.Parallel()
+	tmpl, ok := LookupTemplate("AVERY-5163")
+	if !ok {
+		t.Fatal("template avery-5163 not found")
+	}
+	var items []Label
+	for i := 0; i < 15; i++ {
+		items = append(items, Label{
+			QR:    fmt.Sprintf("https://pm.example.com/tech?code=SER%d", i),
+			Title: fmt.Sprintf("ASSET-%03d", i),
+			Lines: []string{"S/N SER" + strconv.Itoa(i), "HP LaserJet (Room 2)"},
+		})
+	}
+	var buf bytes.Buffer
+	if err := Render(&buf, tmpl, items, Options{Skip: 6, Outline: true}); err != nil {
+		t.Fatalf("Render: %v", err)
+	}
+	pdf := buf.String()
+	if !strings.HasPrefix(pdf, "%PDF-1.4") || !strings.HasSuffix(pdf, "%%EOF\n") {
+		t.Fatal("output is not framed as a PDF")
+	}
+	// 4 free slots on the first sheet, 10 on the second, 1 on the third
+	if !strings.Contains(pdf, "/Count 3 >>") {
+		t.Errorf("expected 3 pages")
+	}
+	if !strings.Contains(pdf, `(ASSET-014) Tj`) || !strings.Contains(pdf, `(HP LaserJet \(Room 2\)) Tj`) {
+		t.Errorf("label text missing or not escaped")
+	}
+
+	// Every xref entry must point at the start of its object
+	m := regexp.MustCompile(`startxref\n(\d+)\n`).FindStringSubmatch(pdf)
+	if m == nil {
+		t.Fatal("startxref missing")
+	}
+	xref, _ := strconv.Atoi(m[1])
+	lines := strings.Split(pdf[xref:], "\n")
+	var count int
+	fmt.Sscanf(lines[1], "0 %d", &count)
+	for i := 1; i < count; i++ {
+		off, _ := strconv.Atoi(lines[2+i][:10])
+		if want := fmt.Sprintf("%d 0 obj", i); !strings.HasPrefix(pdf[off:], want) {
+			t.Fatalf("xref entry %d points at %q", i, pdf[off:off+12])
+		}
+	}
+}
+
+func TestRenderRejectsBadInput(t *testing.T) {
+	t.Parallel()
+	tmpl, _ := LookupTemplate(DefaultTemplate)
+	if err := Render(&bytes.Buffer{}, tmpl, nil, Options{}); err != ErrNoLabels {
+		t.Errorf("empty labels: got %v", err)
+	}
+	if err := Render(&bytes.Buffer{}, tmpl, []Label{{Title: "A"}}, Options{Skip: tmpl.PerSheet}); err == nil {
+		t.Error("skip of a whole sheet should fail")
+	}
+	if _, ok := LookupTemplate("avery-0000"); ok {
+		t.Error("unknown template found")
+	}
+}
@@ -0,0 +1,84 @@
+package labels
+
+import "strings"
+
+// Template describes a sheet of die-cut labels. Geometry is in points
+// (1/72 inch) measured from the top-left corner of the page.
+type Template struct {
+	ID       string `json:"id"`
+	Name     string `json:"name"`
+	Paper    string `json:"paper"`
+	Columns  int    `json:"columns"`
+	Rows     int    `json:"rows"`
+	PerSheet int    `json:"per_sheet"`
+
+	PageWidth   float64 `json:"-"`
+	PageHeight  float64 `json:"-"`
+	LabelWidth  float64 `json:"-"`
+	LabelHeight float64 `json:"-"`
+	Top         float64 `json:"-"` // page edge to the first row
+	Left        float64 `json:"-"` // page edge to the first column
+	PitchX      float64 `json:"-"` // left edge to left edge of adjacent columns
+	PitchY      float64 `json:"-"` // top edge to top edge of adjacent rows
+}
+
+func in(v float64) float64 { return v * 72 }
+func mm(v float64) float64 { return v * 72 / 25.4 }
+
+func newTemplate(t Template) Template {
+	t.PerSheet = t.Columns * t.Rows
+	return t
+}
+
+// templates are common Avery address and asset label sheets, using the
+// layouts published by Avery.
+var templates = []Template{
+	newTemplate(Template{
+		ID: "avery-5160", Name: "Avery 5160 / 8160 (2-5/8\" x 1\", 30 per sheet)", Paper: "letter",
+		Columns: 3, Rows: 10,
+		PageWidth: in(8.5), PageHeight: in(11), LabelWidth: in(2.625), LabelHeight: in(1),
+		Top: in(0.5), Left: in(0.1875), PitchX: in(2.75), PitchY: in(1),
+	}),
+	newTemplate(Template{
+		ID: "avery-5163", Name: "Avery 5163 / 8163 (4\" x 2\", 10 per sheet)", Paper: "letter",
+		Columns: 2, Rows: 5,
+		PageWidth: in(8.5), PageHeight: in(11), LabelWidth: in(4), LabelHeight: in(2),
+		Top: in(0.5), Left: in(0.15625), PitchX: in(4.1875), PitchY: in(2),
+	}),
+	newTemplate(Template{
+		ID: "avery-l7160", Name: "Avery L7160 (63.5 x 38.1 mm, 21 per sheet)", Paper: "a4",
+		Columns: 3, Rows: 7,
+		PageWidth: mm(210), PageHeight: mm(297), LabelWidth: mm(63.5), LabelHeight: mm(38.1),
+		Top: mm(15.15), Left: mm(7.25), PitchX: mm(66.04), PitchY: mm(38.1),
+	}),
+	newTemplate(Template{
+		ID: "avery-l7163", Name: "Avery L7163 (99.1 x 38.1 mm, 14 per sheet)", Paper: "a4",
+		Columns: 2, Rows: 7,
+		PageWidth: mm(210), PageHeight: mm(297), LabelWidth: mm(99.1), LabelHeight: mm(38.1),
+		Top: mm(15.15), Left: mm(4.65), PitchX: mm(101.6), PitchY: mm(38.1),
+	}),
+	newTemplate(Template{
+		ID: "avery-l7651", Name: "Avery L7651 (38.1 x 21.2 mm, 65 per sheet)", Paper: "a4",
+		Columns: 5, Rows: 13,
+		PageWidth: mm(210), PageHeight: mm(297), LabelWidth: mm(38.1), LabelHeight: mm(21.2),
+		Top: mm(10.7), Left: mm(4.75), PitchX: mm(40.64), PitchY: mm(21.2),
+	}),
+}
+
+// DefaultTemplate is used when a request does not name a template.
+const DefaultTemplate = "avery-5160"
+
+// Templates returns the supported label sheets.
+func Templates() []Template {
+	return append([]Template(nil), templates...)
+}
+
+// LookupTemplate finds a template by ID, case-insensitively.
+func LookupTemplate(id string) (Template, bool) {
+	for _, t := range templates {
+		if strings.EqualFold(t.ID, strings.TrimSpace(id)) {
+			return t, true
+		}
+	}
+	return Template{}, false
+}
@@ -29,14 +29,18 @@ import (
 	"path/filepath"
 	"printmaster/common/config"
 	"printmaster/common/logger"
+	pmsettings "printmaster/common/settings"
 	commonutil "printmaster/common/util"
 	sharedweb "printmaster/common/web"
 	wscommon "printmaster/common/ws"
 	alertsapi "printmaster/server/alerts"
 	authz "printmaster/server/authz"
+	"printmaster/server/deepscan"
+	"printmaster/server/devicestatus"
 	emailtpl "printmaster/server/email"
 	"printmaster/server/handlers"
 	metricsapi "printmaster/server/metrics"
+	"printmaster/server/relay"
 	releases "printmaster/server/releases"
 	selfupdate "printmaster/server/selfupdate"
 	serversettings "printmaster/server/settings"
@@ -131,13 +135,52 @@ func (p *Principal) CanAccessTenant(tenantID string) bool {
 	return false
 }
 
+// CanAccessAllTenants reports whether every tenant in ids is one p may
+// access. Resources without a tenant are global and need an admin.
+func (p *Principal) CanAccessAllTenants(ids []string) bool {
+	if p.IsAdmin() {
+		return true
+	}
+	if p == nil || len(ids) == 0 {
+		return false
+	}
+	for _, id := range ids {
+		if !p.CanAccessTenant(id) {
+			return false
+		}
+	}
+	return true
+}
+
+// CanManageUser reports whether p may see, edit or delete u: admins manage
+// everyone, tenant admins manage non-admin users whose tenants all fall
+// within their own.
+func (p *Principal) CanManageUser(u *storage.User) bool {
+	if p == nil || u == nil {
+		return false
+	}
+	if p.IsAdmin() {
+		return true
+	}
+	target := newPrincipal(u)
+	return p.Role == storage.RoleTenantAdmin && !target.IsAdmin() && p.CanAccessAllTenants(target.TenantIDs)
+}
+
+// CanGrantRole reports whether p may give role to a user. Only admins create
+// admins.
+func (p *Principal) CanGrantRole(role storage.Role) bool {
+	return p.IsAdmin() || (p != nil && p.Role == storage.RoleTenantAdmin && role != storage.RoleAdmin)
+}
+
 func rolePriority(role storage.Role) int {
 	switch role {
 	case storage.RoleAdmin:
+		return 4
+	case storage.RoleTenantAdmin:
 		return 3
 	case storage.RoleOperator:
 		return 2
-	case storage.RoleViewer:
+	case storage.RoleAuditor, storage.RoleViewer:
 		return 1
 	default:
 		return 0
@@ -384,8 +427,10 @@ var (
 	intakeWorker        *releases.IntakeWorker // Release intake worker for syncing GitHub releases
 	selfUpdateManager   *selfupdate.Manager    // Self-update manager for server binary updates
 	alertEvaluator      *alertsapi.Evaluator   // Alert evaluation background worker
+	alertNotifier       *alertsapi.Notifier    // Alert notification dispatcher
 	metricsCollector    *metricsapi.Collector  // Server metrics collection background worker
 	credentialsKey      []byte                 // Encryption key for device credentials
+	alertActionKey      []byte                 // Signs one-click alert action links
 )
 
 var processStart = time.Now()
@@ -399,6 +444,15 @@ func init() {
 }
 
 func main() {
+	// Administrative subcommands work directly on the database; "relay" runs
+	// this binary as a regional relay instead of a server
+	if len(os.Args) > 1 && os.Args[1] == "admin" {
+		os.Exit(runAdminCommand(os.Args[2:], os.Stdin, os.Stdout, os.Stderr))
+	}
+	if len(os.Args) > 1 && os.Args[1] == "relay" {
+		os.Exit(runRelayCommand(os.Args[2:], os.Stderr))
+	}
+
 	// Command line flags
 	configPath := flag.String("config", "config.toml", "Configuration file path")
 	generateConfig := flag.Bool("generate-config", false, "Generate default config file and exit")
@@ -722,6 +776,8 @@ func runServer(ctx context.Context, configFlag string) {
 
 	logInfo("Database initialized successfully")
 
+	loadDeviceStatusRules(ctx, serverStore)
+
 	releaseManager, err = releases.NewManager(serverStore, serverLogger, releases.ManagerOptions{})
 	if err != nil {
 		logWarn("Release manifest manager disabled", "error", err)
@@ -729,7 +785,28 @@ func runServer(ctx context.Context, configFlag string) {
 		logWarn("Failed to ensure signing key", "error", err)
 	}
 
+	releaseProxy := releases.ProxyConfig{
+		URL:      cfg.Releases.ProxyURL,
+		Username: cfg.Releases.ProxyUsername,
+		Password: cfg.Releases.ProxyPassword,
+	}
+	releaseClient, err := releases.NewHTTPClient(releaseProxy, 2*time.Minute)
+	if err != nil {
+		logWarn("Invalid release proxy configuration; using environment proxy settings", "error", err)
+		releaseClient, _ = releases.NewHTTPClient(releases.ProxyConfig{}, 2*time.Minute)
+	} else if releaseProxy.URL != "" {
+		logInfo("Release fetches use outbound proxy", "proxy", releaseProxy.Redacted())
+	}
+	tenancy.SetReleaseDownloadClient(releaseClient)
+	tenancy.SetOfflineReleases(cfg.Releases.Offline)
+	if cfg.Releases.Offline {
+		logInfo("Release intake offline; import release bundles to update agents", "import_dir", cfg.Releases.ImportDir)
+	}
+
 	if worker, err := releases.NewIntakeWorker(serverStore, serverLogger, releases.Options{
+		HTTPClient:        releaseClient,
+		Offline:           cfg.Releases.Offline,
+		ImportDir:         cfg.Releases.ImportDir,
 		GitHubToken:       os.Getenv("GITHUB_TOKEN"),
 		UserAgent:         fmt.Sprintf("printmaster-server/%s release-intake", Version),
 		ManifestManager:   releaseManager,
@@ -761,6 +838,12 @@ func runServer(ctx context.Context, configFlag string) {
 		logInfo("Credentials encryption key loaded", "path", credentialsKeyPath)
 	}
 
+	// Key for signing acknowledge/snooze links in alert emails
+	alertActionKeyPath := filepath.Join(dataDir, "alert_actions.key")
+	if alertActionKey, err = commonutil.LoadOrCreateKey(alertActionKeyPath); err != nil {
+		logWarn("Could not prepare alert action link key; emails will not include action links", "error", err, "path", alertActionKeyPath)
+	}
+
 	// Serialize database config for self-update helper
 	dbConfigJSON, err := json.Marshal(map[string]interface{}{
 		"driver":   cfg.Database.Driver,
@@ -910,14 +993,60 @@ func runServer(ctx context.Context, configFlag string) {
 	setupRoutes(cfg)
 
 	// Start alert evaluator background worker
-	alertEvaluator = alertsapi.NewEvaluator(serverStore, alertsapi.EvaluatorConfig{
+	alertEvaluator = alertsapi.NewEvaluator(approvedDeviceStore{Store: serverStore}, alertsapi.EvaluatorConfig{
 		Interval: 60 * time.Second,
 		Logger:   nil, // Uses slog.Default()
+		OnIncident: func(inc *storage.Incident, created bool) {
+			if created {
+				broadcastIncident("created", inc)
+			} else {
+				broadcastIncident("updated", inc)
+			}
+		},
 	})
 	alertEvaluator.Start()
 	defer alertEvaluator.Stop()
 	logInfo("Alert evaluator started", "interval", "60s")
 
+	// Deliver per-user alert notifications and digests
+	go alertNotifier.RunUserNotifications(ctx, time.Minute)
+
+	// Bridge agents on the MQTT transport into the upload handlers
+	if cfg.MQTT.Enabled {
+		if bridge, err := startMQTTBridge(cfg.MQTT, serverStore, activeIngestQueues); err != nil {
+			logError("Failed to start MQTT bridge", "error", err)
+		} else {
+			defer bridge.stop()
+		}
+	}
+
+	// Receive scan-to-email tests from MFPs
+	if cfg.ScanMail.Enabled {
+		if sink, err := startScanMail(cfg.ScanMail); err != nil {
+			logError("Failed to start scan mail sink", "error", err)
+		} else {
+			defer sink.Close()
+		}
+	}
+
+	// Move old metrics into Parquet cold storage
+	if cfg.Archive.Enabled {
+		metricsArchiver = newMetricsArchiver(cfg.Archive, serverStore, dataDir)
+		go metricsArchiver.Run(ctx, 24*time.Hour, logInfo)
+		logInfo("Metrics archive enabled", "min_age_years", cfg.Archive.MinAgeYears)
+	}
+
+	// Start deep scan orchestrator (scheduled full walks across agents)
+	deepScanOrchestrator = deepscan.NewOrchestrator(serverStore, wsAgentClient{}, deepscan.Config{})
+	deepScanOrchestrator.Start()
+	defer deepScanOrchestrator.Stop()
+	logInfo("Deep scan orchestrator started", "interval", "60s")
+
+	// Run scheduled reports and email them to their recipients
+	reportScheduler = newReportScheduler()
+	reportScheduler.Start()
+	defer reportScheduler.Stop()
+
 	// Start agent callback token cleanup goroutine
 	go func() {
 		ticker := time.NewTicker(5 * time.Minute)
@@ -946,6 +1075,26 @@ func runServer(ctx context.Context, configFlag string) {
 		}
 	}()
 
+	// Save API key usage counts and warn owners of expiring keys
+	go runAPIKeyMaintenance(ctx)
+
+	// Start parse sample, device change feed and scan path check pruning goroutine
+	go func() {
+		ticker := time.NewTicker(24 * time.Hour)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				pruneParseSamples(ctx)
+				pruneDeviceChanges(ctx)
+				pruneScanPathChecks(ctx, cfg.ScanMail.RetentionDays)
+				pruneVendorWebhooks(ctx, cfg.Vendor.RetentionDays)
+			}
+		}
+	}()
+
 	// Start server metrics collector for Netdata-style dashboards
 	metricsCollector = metricsapi.NewCollector(serverStore, metricsapi.CollectorConfig{
 		CollectionInterval:  5 * time.Second, // 5s for live dashboard updates
@@ -1623,6 +1772,8 @@ func requireAuth(next http.HandlerFunc) http.HandlerFunc {
 
 			if isBlocked {
 				http.Error(w, "Too many failed attempts. Try again later.", http.StatusTooManyRequests)
+			} else if errors.Is(err, storage.ErrAgentTokenExpired) {
+				http.Error(w, "Token expired", http.StatusUnauthorized)
 			} else {
 				http.Error(w, "Invalid token", http.StatusUnauthorized)
 			}
@@ -1660,19 +1811,39 @@ func sessionTokenFromRequest(r *http.Request) string {
 }
 
 func loadUserForSessionToken(token string) (*storage.User, error) {
+	_, user, err := loadSessionForToken(token)
+	return user, err
+}
+
+// loadSessionForToken validates a session or API token and returns it with
+// its user. API tokens are exempt from the idle timeout.
+func loadSessionForToken(token string) (*storage.Session, *storage.User, error) {
 	if token == "" {
-		return nil, errSessionMissing
+		return nil, nil, errSessionMissing
 	}
 	ctx := context.Background()
 	ses, err := serverStore.GetSessionByToken(ctx, token)
 	if err != nil {
-		return nil, errSessionInvalid
+		return nil, nil, errSessionInvalid
+	}
+	now := time.Now().UTC()
+	idle := now.Sub(ses.LastSeenAt)
+	if timeout := sessionIdleTimeout(); timeout > 0 && idle > timeout && ses.Kind != storage.SessionKindAPI {
+		if err := serverStore.DeleteSessionByHash(ctx, ses.Token); err != nil {
+			serverLogger.Warn("Failed to delete idle session", "user_id", ses.UserID, "error", err)
+		}
+		return nil, nil, errSessionInvalid
+	}
+	if idle > sessionTouchInterval {
+		if err := serverStore.TouchSession(ctx, ses.Token, now); err != nil {
+			serverLogger.Debug("Failed to record session activity", "user_id", ses.UserID, "error", err)
+		}
 	}
 	user, err := serverStore.GetUserByID(ctx, ses.UserID)
 	if err != nil {
-		return nil, errSessionUser
+		return nil, nil, errSessionUser
 	}
-	return user, nil
+	return ses, user, nil
 }
 
 // requireWebAuth validates a session token from cookie or Authorization header
@@ -1684,7 +1855,7 @@ func requireWebAuth(next http.HandlerFunc) http.HandlerFunc {
 			return
 		}
 
-		user, err := loadUserForSessionToken(token)
+		ses, user, err := loadSessionForToken(token)
 		if err != nil {
 			switch err {
 			case errSessionInvalid:
@@ -1698,6 +1869,13 @@ func requireWebAuth(next http.HandlerFunc) http.HandlerFunc {
 		}
 
 		ctx2 := contextWithPrincipal(r.Context(), user)
+		if ses.Kind == storage.SessionKindAPI {
+			// Count integration traffic per key for usage analytics
+			rec := &apiUsageStatusWriter{ResponseWriter: w, status: http.StatusOK}
+			next.ServeHTTP(rec, r.WithContext(ctx2))
+			apiUsage.record(ses.Token, r.Method, r.URL.Path, rec.status, time.Now())
+			return
+		}
 		next.ServeHTTP(w, r.WithContext(ctx2))
 	}
 }
@@ -1818,9 +1996,28 @@ func handleAuthLogin(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(map[string]interface{}{"success": true, "token": ses.Token, "expires_at": ses.ExpiresAt.Format(time.RFC3339)})
 }
 
+// sessionTouchInterval limits how often a busy session's last activity is written.
+const sessionTouchInterval = time.Minute
+
+// sessionLifetimeMinutes returns the absolute lifetime for new login sessions.
+func sessionLifetimeMinutes() int {
+	if serverConfig != nil && serverConfig.Security.SessionLifetimeHours > 0 {
+		return serverConfig.Security.SessionLifetimeHours * 60
+	}
+	return 60 * 24
+}
+
+// sessionIdleTimeout returns how long a session may go unused (0 = no limit).
+func sessionIdleTimeout() time.Duration {
+	if serverConfig == nil || serverConfig.Security.SessionIdleMinutes <= 0 {
+		return 0
+	}
+	return time.Duration(serverConfig.Security.SessionIdleMinutes) * time.Minute
+}
+
 func createSessionCookie(w http.ResponseWriter, r *http.Request, userID int64) (*storage.Session, error) {
 	ctx := context.Background()
-	ses, err := serverStore.CreateSession(ctx, userID, 60*24)
+	ses, err := serverStore.CreateSessionForClient(ctx, userID, sessionLifetimeMinutes(), extractClientIP(r), r.Header.Get("User-Agent"))
 	if err != nil {
 		return nil, err
 	}
@@ -1954,9 +2151,6 @@ func handleUsers(w http.ResponseWriter, r *http.Request) {
 	ctx := context.Background()
 	switch r.Method {
 	case http.MethodGet:
-		if !authorizeOrReject(w, r, authz.ActionSettingsServerRead, authz.ResourceRef{}) {
-			return
-		}
 		if !authorizeOrReject(w, r, authz.ActionUsersRead, authz.ResourceRef{}) {
 			return
 		}
@@ -1966,13 +2160,20 @@ func handleUsers(w http.ResponseWriter, r *http.Request) {
 			http.Error(w, "failed to list users", http.StatusInternalServerError)
 			return
 		}
+		// Tenant admins only see the users they manage
+		if principal := getPrincipal(r); !principal.IsAdmin() {
+			visible := users[:0]
+			for _, u := range users {
+				if principal.CanManageUser(u) {
+					visible = append(visible, u)
+				}
+			}
+			users = visible
+		}
 		w.Header().Set("Content-Type", "application/json")
 		json.NewEncoder(w).Encode(users)
 		return
 	case http.MethodPost:
-		if !authorizeOrReject(w, r, authz.ActionSettingsServerWrite, authz.ResourceRef{}) {
-			return
-		}
 		if !authorizeOrReject(w, r, authz.ActionUsersWrite, authz.ResourceRef{}) {
 			return
 		}
@@ -2006,6 +2207,9 @@ func handleUsers(w http.ResponseWriter, r *http.Request) {
 				tenantIDs = []string{tid}
 			}
 		}
+		if !checkUserGrant(w, r, role, tenantIDs) {
+			return
+		}
 		u := &storage.User{
 			Username:  req.Username,
 			Role:      role,
@@ -2050,6 +2254,28 @@ func handleUsers(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// checkUserGrant rejects the request unless the caller may give role and
+// tenantIDs to a user. Tenant admins cannot create admins or global users.
+func checkUserGrant(w http.ResponseWriter, r *http.Request, role storage.Role, tenantIDs []string) bool {
+	principal := getPrincipal(r)
+	if principal.IsAdmin() {
+		return true
+	}
+	if !principal.CanGrantRole(role) {
+		http.Error(w, "forbidden: cannot grant role "+string(role), http.StatusForbidden)
+		return false
+	}
+	if len(tenantIDs) == 0 {
+		http.Error(w, "tenant_ids required", http.StatusBadRequest)
+		return false
+	}
+	if !principal.CanAccessAllTenants(tenantIDs) {
+		http.Error(w, "forbidden: tenant outside your scope", http.StatusForbidden)
+		return false
+	}
+	return true
+}
+
 // handleAuthLogout removes the session token
 func handleAuthLogout(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
@@ -2171,7 +2397,7 @@ func generateAgentCallbackToken(user *storage.User, agentID, callbackURL string)
 		Token:       token,
 		UserID:      user.ID,
 		Username:    user.Username,
-		Role:        string(user.Role),
+		Role:        string(storage.AgentRole(user.Role)),
 		TenantID:    user.TenantID,
 		TenantIDs:   user.TenantIDs,
 		AgentID:     agentID,
@@ -2422,7 +2648,8 @@ func handleAgentAuthCallbackValidate(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
-// handleUser handles single-user operations: GET, PUT, DELETE (admin only)
+// handleUser handles single-user operations: GET, PUT, DELETE. Tenant admins
+// only reach the users they manage.
 func handleUser(w http.ResponseWriter, r *http.Request) {
 	// Extract ID from path /api/v1/users/{id}
 	idStr := strings.TrimPrefix(r.URL.Path, "/api/v1/users/")
@@ -2443,7 +2670,7 @@ func handleUser(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 		u, err := serverStore.GetUserByID(ctx, id)
-		if err != nil {
+		if err != nil || !getPrincipal(r).CanManageUser(u) {
 			http.Error(w, "user not found", http.StatusNotFound)
 			return
 		}
@@ -2456,7 +2683,7 @@ func handleUser(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 		u, err := serverStore.GetUserByID(ctx, id)
-		if err != nil {
+		if err != nil || !getPrincipal(r).CanManageUser(u) {
 			http.Error(w, "user not found", http.StatusNotFound)
 			return
 		}
@@ -2501,7 +2728,7 @@ func handleUser(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 		u, err := serverStore.GetUserByID(ctx, id)
-		if err != nil {
+		if err != nil || !getPrincipal(r).CanManageUser(u) {
 			http.Error(w, "user not found", http.StatusNotFound)
 			return
 		}
@@ -2526,6 +2753,9 @@ func handleUser(w http.ResponseWriter, r *http.Request) {
 		if req.Email != "" {
 			u.Email = req.Email
 		}
+		if !checkUserGrant(w, r, u.Role, u.TenantIDs) {
+			return
+		}
 		if err := serverStore.UpdateUser(ctx, u); err != nil {
 			serverLogger.Error("Failed to update user", "user_id", id, "username", u.Username, "error", err)
 			http.Error(w, "failed to update user", http.StatusInternalServerError)
@@ -2667,6 +2897,185 @@ func handleDeleteSession(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(map[string]bool{"success": true})
 }
 
+// revokeSessionsRequest selects sessions to revoke in bulk.
+type revokeSessionsRequest struct {
+	Keys   []string `json:"keys"`    // Stored token hashes
+	UserID int64    `json:"user_id"` // Revoke every session of this user (force logout)
+}
+
+// handleRevokeSessions revokes several sessions at once, or every session of a
+// user when user_id is set (admin only)
+func handleRevokeSessions(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !authorizeOrReject(w, r, authz.ActionSessionsWrite, authz.ResourceRef{}) {
+		return
+	}
+	var req revokeSessionsRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid json", http.StatusBadRequest)
+		return
+	}
+	if len(req.Keys) == 0 && req.UserID == 0 {
+		http.Error(w, "keys or user_id required", http.StatusBadRequest)
+		return
+	}
+
+	ctx := r.Context()
+	var target *storage.User
+	if req.UserID != 0 {
+		u, err := serverStore.GetUserByID(ctx, req.UserID)
+		if err != nil {
+			http.Error(w, "user not found", http.StatusNotFound)
+			return
+		}
+		target = u
+	}
+
+	var revoked int64
+	for _, key := range req.Keys {
+		key = strings.TrimSpace(key)
+		if key == "" {
+			continue
+		}
+		n, err := serverStore.DeleteSessionByHashWithCount(ctx, key)
+		if err != nil {
+			serverLogger.Error("Failed to delete session", "key_prefix", key[:min(12, len(key))], "error", err)
+			http.Error(w, "failed to revoke sessions", http.StatusInternalServerError)
+			return
+		}
+		revoked += n
+	}
+	if target != nil {
+		n, err := serverStore.DeleteUserSessions(ctx, target.ID, "")
+		if err != nil {
+			serverLogger.Error("Failed to log out user", "user_id", target.ID, "error", err)
+			http.Error(w, "failed to revoke sessions", http.StatusInternalServerError)
+			return
+		}
+		revoked += n
+	}
+
+	actorType, actorID, actorName, actorTenant := auditActorFromPrincipal(r)
+	entry := &storage.AuditEntry{
+		ActorType:  actorType,
+		ActorID:    actorID,
+		ActorName:  actorName,
+		TenantID:   actorTenant,
+		Action:     "session.revoke_bulk",
+		TargetType: "session",
+		Details:    fmt.Sprintf("Revoked %d sessions", revoked),
+		Metadata: map[string]interface{}{
+			"requested": len(req.Keys),
+			"revoked":   revoked,
+		},
+		IPAddress: extractClientIP(r),
+	}
+	if target != nil {
+		entry.Action = "user.force_logout"
+		entry.TargetType = "user"
+		entry.TargetID = strconv.FormatInt(target.ID, 10)
+		entry.Details = fmt.Sprintf("Logged out %s everywhere (%d sessions)", target.Username, revoked)
+	}
+	logAuditEntry(ctx, entry)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"success": true, "revoked": revoked})
+}
+
+// handleMySessions lets any signed-in user manage their own sessions:
+//
+//	GET    /api/v1/auth/sessions         list the caller's sessions
+//	DELETE /api/v1/auth/sessions/{key}   revoke one of them
+//	DELETE /api/v1/auth/sessions/others  revoke all but the current session
+func handleMySessions(w http.ResponseWriter, r *http.Request) {
+	principal := getPrincipal(r)
+	if principal == nil || principal.User == nil {
+		http.Error(w, "unauthenticated", http.StatusUnauthorized)
+		return
+	}
+	user := principal.User
+	ctx := r.Context()
+	currentHash := storage.TokenHash(sessionTokenFromRequest(r))
+	key := strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/v1/auth/sessions"), "/")
+
+	switch r.Method {
+	case http.MethodGet:
+		if key != "" {
+			http.Error(w, "not found", http.StatusNotFound)
+			return
+		}
+		all, err := serverStore.ListSessions(ctx)
+		if err != nil {
+			serverLogger.Error("Failed to list sessions", "error", err)
+			http.Error(w, "failed to list sessions", http.StatusInternalServerError)
+			return
+		}
+		sessions := make([]*storage.Session, 0)
+		for _, s := range all {
+			if s.UserID == user.ID {
+				sessions = append(sessions, s)
+			}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(sessions)
+	case http.MethodDelete:
+		var revoked int64
+		var err error
+		switch key {
+		case "":
+			http.Error(w, "session key required", http.StatusBadRequest)
+			return
+		case "others":
+			revoked, err = serverStore.DeleteUserSessions(ctx, user.ID, currentHash)
+		default:
+			all, listErr := serverStore.ListSessions(ctx)
+			if listErr != nil {
+				err = listErr
+				break
+			}
+			owned := false
+			for _, s := range all {
+				if s.Token == key && s.UserID == user.ID {
+					owned = true
+					break
+				}
+			}
+			if !owned {
+				http.Error(w, "session not found", http.StatusNotFound)
+				return
+			}
+			revoked, err = serverStore.DeleteSessionByHashWithCount(ctx, key)
+			if key == currentHash {
+				clearSessionCookie(w, r)
+			}
+		}
+		if err != nil {
+			serverLogger.Error("Failed to revoke own sessions", "user_id", user.ID, "error", err)
+			http.Error(w, "failed to revoke sessions", http.StatusInternalServerError)
+			return
+		}
+		logAuditEntry(ctx, &storage.AuditEntry{
+			ActorType:  storage.AuditActorUser,
+			ActorID:    user.Username,
+			ActorName:  user.Username,
+			TenantID:   user.TenantID,
+			Action:     "session.revoke_own",
+			TargetType: "session",
+			TargetID:   key,
+			Details:    fmt.Sprintf("User revoked %d of their sessions", revoked),
+			IPAddress:  extractClientIP(r),
+			UserAgent:  r.Header.Get("User-Agent"),
+		})
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{"success": true, "revoked": revoked})
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
 // validateEmailAddress checks for email header injection attacks.
 // Returns the sanitized email and nil error if valid, or empty string and error if invalid.
 func validateEmailAddress(email string) (string, error) {
@@ -2680,22 +3089,9 @@ func validateEmailAddress(email string) (string, error) {
 	return sanitizeEmailHeader(email), nil
 }
 
-// sendHTMLEmail sends an email with optional HTML and plain-text content.
-// If htmlBody is empty, sends plain text only. If textBody is empty, sends HTML only.
-// If both are provided, sends multipart/alternative (most email clients prefer HTML but fall back to text).
-func sendHTMLEmail(to string, subject string, htmlBody string, textBody string) error {
-	// Validate email address to prevent header injection attacks
-	validatedTo, err := validateEmailAddress(to)
-	if err != nil {
-		return fmt.Errorf("invalid recipient address: %w", err)
-	}
-
-	// Sanitize subject header
-	validatedSubject := sanitizeEmailHeader(subject)
-
-	var host, user, pass, from string
-	var port int
-
+// smtpSettings returns the SMTP server and credentials, preferring the
+// settings saved in the UI over the SMTP_* environment variables.
+func smtpSettings() (host string, port int, user, pass, from string, err error) {
 	// Prefer settings saved in-memory (UI/settings) when enabled
 	if serverConfig != nil && serverConfig.SMTP.Enabled {
 		host = serverConfig.SMTP.Host
@@ -2730,7 +3126,27 @@ func sendHTMLEmail(to string, subject string, htmlBody string, textBody string)
 	}
 
 	if host == "" || port == 0 {
-		return fmt.Errorf("SMTP not configured")
+		return "", 0, "", "", "", fmt.Errorf("SMTP not configured")
+	}
+	return host, port, user, pass, from, nil
+}
+
+// sendHTMLEmail sends an email with optional HTML and plain-text content.
+// If htmlBody is empty, sends plain text only. If textBody is empty, sends HTML only.
+// If both are provided, sends multipart/alternative (most email clients prefer HTML but fall back to text).
+func sendHTMLEmail(to string, subject string, htmlBody string, textBody string) error {
+	// Validate email address to prevent header injection attacks
+	validatedTo, err := validateEmailAddress(to)
+	if err != nil {
+		return fmt.Errorf("invalid recipient address: %w", err)
+	}
+
+	// Sanitize subject header
+	validatedSubject := sanitizeEmailHeader(subject)
+
+	host, port, user, pass, from, err := smtpSettings()
+	if err != nil {
+		return err
 	}
 
 	// Sanitize from address to prevent header injection
@@ -2961,6 +3377,13 @@ func handleUserInvite(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "email required", http.StatusBadRequest)
 		return
 	}
+	var inviteTenants []string
+	if tid := strings.TrimSpace(req.TenantID); tid != "" {
+		inviteTenants = []string{tid}
+	}
+	if !checkUserGrant(w, r, storage.NormalizeRole(req.Role), inviteTenants) {
+		return
+	}
 
 	ctx := context.Background()
 
@@ -3396,6 +3819,8 @@ func setupRoutes(cfg *Config) {
 		ProtocolVersion: ProtocolVersion,
 		ProcessStart:    processStart,
 		TenancyChecker:  tenancy.IsEnabled,
+		ReadinessChecks: readinessChecks(),
+		LivenessChecks:  livenessChecks(),
 	})
 	healthAPI.RegisterRoutes(http.DefaultServeMux)
 
@@ -3415,6 +3840,8 @@ func setupRoutes(cfg *Config) {
 	// Logout (requires valid session)
 	http.HandleFunc("/api/v1/auth/logout", requireWebAuth(handleAuthLogout))
 	http.HandleFunc("/api/v1/auth/me", requireWebAuth(handleAuthMe))
+	http.HandleFunc("/api/v1/auth/sessions", requireWebAuth(handleMySessions))
+	http.HandleFunc("/api/v1/auth/sessions/", requireWebAuth(handleMySessions))
 	// Agent callback auth (for agents redirecting users through server login)
 	http.HandleFunc("/api/v1/auth/agent-callback", requireWebAuth(handleAgentAuthCallback))
 	http.HandleFunc("/api/v1/auth/agent-callback/validate", handleAgentAuthCallbackValidate) // Public - called by agents
@@ -3427,11 +3854,15 @@ func setupRoutes(cfg *Config) {
 	http.HandleFunc("/api/v1/users/invite", requireWebAuth(handleUserInvite)) // Must be before /users/ catch-all
 	http.HandleFunc("/api/v1/users/invite/validate", handleInviteValidate)    // Public - validate invitation token
 	http.HandleFunc("/api/v1/users/invite/accept", handleInviteAccept)        // Public - accept invitation and create account
+	http.HandleFunc("/api/v1/users/me/notification-preferences", requireWebAuth(handleMyNotificationPrefs))
 	http.HandleFunc("/api/v1/users/", requireWebAuth(handleUser))
 	http.HandleFunc("/api/v1/users/password-policy", handlePasswordPolicy)
 	// Sessions management: list and revoke sessions
 	http.HandleFunc("/api/v1/sessions", requireWebAuth(handleListSessions))
 	http.HandleFunc("/api/v1/sessions/", requireWebAuth(handleDeleteSession))
+	http.HandleFunc("/api/v1/sessions/revoke", requireWebAuth(handleRevokeSessions))
+	http.HandleFunc("/api/v1/api-keys", requireWebAuth(handleAPIKeys))
+	http.HandleFunc("/api/v1/api-keys/", requireWebAuth(handleAPIKey))
 
 	// Password reset endpoints (public)
 	http.HandleFunc("/api/v1/users/reset/request", handlePasswordResetRequest)
@@ -3444,6 +3875,8 @@ func setupRoutes(cfg *Config) {
 	http.HandleFunc("/api/v1/agents/register", handleAgentRegister) // No auth - this generates token
 	http.HandleFunc("/api/v1/agents/heartbeat", requireAuth(handleAgentHeartbeat))
 	http.HandleFunc("/api/v1/agents/device-credentials", requireAuth(handleAgentDeviceCredentials)) // Agent requests device credentials
+	http.HandleFunc("/api/v1/audit/log", requireAuth(handleAgentAuditLog))                          // Agent-reported audit events
+	http.HandleFunc("/api/v1/agents/alerts", requireAuth(handleAgentAlerts))                        // Agent-raised local alerts
 	http.HandleFunc("/api/v1/agents/device-auth/start", handleAgentDeviceAuthStart)
 	http.HandleFunc("/api/v1/agents/device-auth/poll", handleAgentDeviceAuthPoll)
 	http.HandleFunc("/api/v1/agents/list", requireWebAuth(handleAgentsList))       // List all agents (for UI)
@@ -3458,6 +3891,9 @@ func setupRoutes(cfg *Config) {
 	http.HandleFunc("/api/v1/agents/update/manifest", requireAuth(handleAgentUpdateManifest))
 	http.HandleFunc("/api/v1/agents/update/download/", requireAuth(handleAgentUpdateDownload))
 	http.HandleFunc("/api/v1/agents/update/telemetry", requireAuth(handleAgentUpdateTelemetry))
+	http.HandleFunc("/api/v1/agents/update/runs", requireWebAuth(handleAgentUpdateRuns))
+	http.HandleFunc("/api/v1/agents/update/failures", requireWebAuth(handleAgentUpdateFailures))
+	http.HandleFunc("/api/v1/agents/tokens/rotate", requireWebAuth(handleAgentTokenRotate))
 
 	// Tenancy & join-token routes. The register-with-token path must remain
 	// available even if admins disable tenancy, so register routes always and
@@ -3488,6 +3924,7 @@ func setupRoutes(cfg *Config) {
 		return nil
 	})
 	tenancy.RegisterRoutes(serverStore)
+	registerTenantOnboardingRoutes()
 	logInfo("Tenancy routes registered", "enabled", featureEnabled)
 
 	settingsAPI, err := serversettings.NewAPI(serverStore, settingsResolver, serversettings.APIOptions{
@@ -3531,6 +3968,17 @@ func setupRoutes(cfg *Config) {
 			return ""
 		},
 		AuditLogger: logRequestAudit,
+		LatestVersion: func(ctx context.Context, platform, arch, channel string) (string, error) {
+			if releaseManager == nil {
+				return "", nil
+			}
+			manifest, err := releaseManager.GetLatestManifest(ctx, "agent", platform, arch, channel)
+			if err != nil || manifest == nil {
+				// No matching release is not an error for a simulation
+				return "", nil
+			}
+			return manifest.Version, nil
+		},
 	})
 	if err != nil {
 		logFatal("Failed to initialize update policy API", "error", err)
@@ -3543,7 +3991,7 @@ func setupRoutes(cfg *Config) {
 	logInfo("Update policy routes registered", "enabled", featureEnabled)
 
 	// Alerts API routes
-	alertNotifier := alertsapi.NewNotifier(serverStore, alertsapi.NotifierConfig{
+	alertNotifier = alertsapi.NewNotifier(serverStore, alertsapi.NotifierConfig{
 		Logger:     nil, // Uses slog.Default()
 		HTTPClient: &http.Client{Timeout: 30 * time.Second},
 		MaxRetries: 3,
@@ -3556,6 +4004,9 @@ func setupRoutes(cfg *Config) {
 			Pass:    cfg.SMTP.Pass,
 			From:    cfg.SMTP.From,
 		},
+		BaseURL:       cfg.Server.PublicURL,
+		ActionLinkKey: alertActionKey,
+		ReplyTo:       cfg.SMTP.AlertReplyTo,
 	})
 	alertsAPI, err := alertsapi.NewAPI(serverStore, alertsapi.APIOptions{
 		AuthMiddleware: requireWebAuth,
@@ -3584,6 +4035,7 @@ func setupRoutes(cfg *Config) {
 	http.HandleFunc("/api/v1/reports", requireWebAuth(handleReports))
 	http.HandleFunc("/api/v1/reports/summary", requireWebAuth(handleReportSummary))
 	http.HandleFunc("/api/v1/reports/types", requireWebAuth(handleReportTypes))
+	http.HandleFunc("/api/v1/reports/builder/fields", requireWebAuth(handleReportBuilderFields))
 	http.HandleFunc("/api/v1/reports/", requireWebAuth(handleReport))
 	http.HandleFunc("/api/v1/report-schedules", requireWebAuth(handleReportSchedulesCollection))
 	http.HandleFunc("/api/v1/report-schedules/", requireWebAuth(handleSchedule))
@@ -3606,6 +4058,7 @@ func setupRoutes(cfg *Config) {
 
 	// Release sync trigger and artifacts list endpoints
 	http.HandleFunc("/api/v1/releases/sync", requireWebAuth(handleReleasesSync))
+	http.HandleFunc("/api/v1/releases/import", requireWebAuth(handleReleasesImport))
 	http.HandleFunc("/api/v1/releases/artifacts", requireWebAuth(handleReleasesArtifacts))
 	http.HandleFunc("/api/v1/releases/latest-agent-version", requireWebAuth(handleLatestAgentVersion))
 
@@ -3656,9 +4109,15 @@ func setupRoutes(cfg *Config) {
 	http.HandleFunc("/auth/oidc/start/", handleOIDCStart)
 	http.HandleFunc("/auth/oidc/callback", handleOIDCCallback)
 
-	http.HandleFunc("/api/v1/devices/batch", requireAuth(handleDevicesBatch))
+	// Agent uploads run on bounded worker pools; overload answers 429 + Retry-After
+	ingestQueues := setupIngestQueues(cfg)
+	http.HandleFunc("/api/v1/devices/batch", requireAuth(ingestQueues.devices.Wrap(handleDevicesBatch)))
 	http.HandleFunc("/api/v1/devices/list", requireWebAuth(handleDevicesList)) // List all devices (for UI)
-	http.HandleFunc("/api/v1/metrics/batch", requireAuth(handleMetricsBatch))
+	http.HandleFunc("/api/v1/metrics/batch", requireAuth(ingestQueues.metrics.Wrap(handleMetricsBatch)))
+	http.HandleFunc("/api/v1/meter-reads/batch", requireAuth(ingestQueues.meterReads.Wrap(handleMeterReadsBatch)))
+	http.HandleFunc("/api/v1/parse-samples/batch", requireAuth(handleParseSamplesBatch))
+	http.HandleFunc("/api/v1/unknown-devices/batch", requireAuth(handleUnknownDevicesBatch))
+	http.HandleFunc("/api/v1/ingest/stats", requireWebAuth(handleIngestStats))
 
 	// Dashboard API - hierarchical tenant/agent/device tree view
 	http.HandleFunc("/api/v1/dashboard/tree", requireWebAuth(handleDashboardTree))
@@ -3671,6 +4130,67 @@ func setupRoutes(cfg *Config) {
 	http.HandleFunc("/api/report/stream", requireWebAuth(handleDeviceReportStreamProxy)) // Proxy streaming device reports to agent
 	http.HandleFunc("/api/v1/devices/delete", requireWebAuth(handleDeviceDelete))        // Delete device from server and optionally agent
 
+	// Device status normalization rules (admin-editable)
+	http.HandleFunc("/api/v1/device-status/rules", requireWebAuth(handleDeviceStatusRules))
+	http.HandleFunc("/api/v1/device-status/classify", requireWebAuth(handleDeviceStatusClassify))
+
+	// Cartridge baseline library (rated toner yields per printer model)
+	http.HandleFunc("/api/v1/supplies/yield-baselines", requireWebAuth(handleYieldBaselines))
+
+	// Scheduled deep scans (full walk + parse capture) across agents
+	http.HandleFunc("/api/v1/deep-scans/schedules", requireWebAuth(handleDeepScanSchedules))
+	http.HandleFunc("/api/v1/deep-scans/schedules/", requireWebAuth(handleDeepScanSchedule))
+	http.HandleFunc("/api/v1/deep-scans/run", requireWebAuth(handleDeepScanRunNow))
+	http.HandleFunc("/api/v1/deep-scans/runs", requireWebAuth(handleDeepScanRuns))
+	http.HandleFunc("/api/v1/deep-scans/runs/", requireWebAuth(handleDeepScanRun))
+
+	// Read-only share links; the share view itself is public and authorized by its token
+	http.HandleFunc("/api/v1/share-links", requireWebAuth(handleShareLinks))
+	http.HandleFunc("/api/v1/share-links/", requireWebAuth(handleShareLink))
+	http.HandleFunc("/api/v1/action-approvals", requireWebAuth(handleActionApprovals))
+	http.HandleFunc("/api/v1/action-approvals/", requireWebAuth(handleActionApproval))
+	http.HandleFunc("/api/v1/share/", handleShareView)
+	http.HandleFunc("/share/", handleSharePage)
+
+	// Agent settings drift from assigned policy
+	http.HandleFunc("/api/v1/config-drift", requireWebAuth(handleConfigDriftList))
+	http.HandleFunc("/api/v1/config-drift/", requireWebAuth(handleConfigDriftAgent))
+
+	// Historical volumes imported from PaperCut/SafeQ exports
+	http.HandleFunc("/api/v1/imports/", requireWebAuth(handleThirdPartyImport))
+
+	// Device notes, maintenance log and per-device timeline
+	http.HandleFunc("/api/v1/device-notes", requireWebAuth(handleDeviceNotes))
+	http.HandleFunc("/api/v1/device-notes/", requireWebAuth(handleDeviceNote))
+	http.HandleFunc("/api/v1/incidents", requireWebAuth(handleIncidents))
+	http.HandleFunc("/api/v1/incidents/", requireWebAuth(handleIncident))
+	http.HandleFunc("/api/v1/data-quality", requireWebAuth(handleDataQuality))
+	http.HandleFunc("/api/v1/data-quality/", requireWebAuth(handleDataQuality))
+	http.HandleFunc("/api/v1/unknown-devices", requireWebAuth(handleUnknownDevices))
+	http.HandleFunc("/api/v1/detection-rules", requireWebAuth(handleDetectionRules))
+	http.HandleFunc("/api/v1/detection-rules/", requireWebAuth(handleDetectionRule))
+	http.HandleFunc("/api/v1/devices/timeline", requireWebAuth(handleDeviceTimeline))
+	http.HandleFunc("/api/v1/devices/labels", requireWebAuth(handleDeviceLabels))
+	http.HandleFunc("/api/v1/devices/labels/templates", requireWebAuth(handleDeviceLabelTemplates))
+	http.HandleFunc("/api/v1/devices/compare", requireWebAuth(handleDeviceCompare))
+	http.HandleFunc("/api/v1/devices/export", requireWebAuth(handleDevicesExport))
+
+	// Device approval workflow (newly discovered devices pending operator review)
+	http.HandleFunc("/api/v1/device-approvals", requireWebAuth(handleDeviceApprovals))
+	http.HandleFunc("/api/v1/device-approvals/approve", requireWebAuth(handleDeviceApprovalsApprove))
+	http.HandleFunc("/api/v1/device-approvals/reject", requireWebAuth(handleDeviceApprovalsReject))
+	http.HandleFunc("/api/v1/device-approvals/policy", requireWebAuth(handleDeviceApprovalPolicy))
+
+	// MSP billing export (per-tenant monthly counters with period locking)
+	http.HandleFunc("/api/v1/billing/export", requireWebAuth(handleBillingExport))
+	http.HandleFunc("/api/v1/billing/finalize", requireWebAuth(handleBillingFinalize))
+
+	// Certified meter reads: reconciliation between periods and anomaly review
+	http.HandleFunc("/api/v1/meter-reads", requireWebAuth(handleMeterReads))
+	http.HandleFunc("/api/v1/meter-reads/reconcile", requireWebAuth(handleMeterReadsReconcile))
+	http.HandleFunc("/api/v1/meter-reads/anomalies", requireWebAuth(handleMeterReadAnomalies))
+	http.HandleFunc("/api/v1/meter-reads/anomalies/resolve", requireWebAuth(handleMeterReadAnomalyResolve))
+
 	// Web UI endpoints - keep landing/static public so login assets load
 	http.HandleFunc("/", handleWebUI)
 	http.HandleFunc("/login", func(w http.ResponseWriter, r *http.Request) {
@@ -3696,11 +4216,45 @@ func setupRoutes(cfg *Config) {
 		w.Write(content)
 	})
 	http.HandleFunc("/device-auth/", handleDeviceAuthPage)
+	http.HandleFunc("/tech", handleTechPage) // Mobile technician view (redirects to login)
+	http.HandleFunc("/api/v1/tech/lookup", requireWebAuth(handleTechLookup))
 	http.HandleFunc("/api/v1/device-auth/requests/", requireWebAuth(handleDeviceAuthRequestRoute))
 	http.HandleFunc("/static/", handleStatic)
 
 	// UI metrics summary endpoint (protected)
 	http.HandleFunc("/api/metrics", requireWebAuth(handleMetricsSummary))
+	http.HandleFunc("/api/v1/kpi", requireWebAuth(handleFleetKPI))
+	if cfg == nil || cfg.Prometheus.Enabled {
+		var networks []*net.IPNet
+		if cfg != nil {
+			networks = prometheusAllowedNetworks(cfg.Prometheus)
+		}
+		http.HandleFunc("/metrics", newPrometheusHandler(networks))
+	}
+	http.HandleFunc("/api/v1/search", requireWebAuth(handleSearch))
+	http.HandleFunc("/api/v1/changes", requireWebAuth(handleDeviceChanges))
+	http.HandleFunc("/api/v1/scan-path", requireWebAuth(handleScanPathChecks))
+
+	// Vendor cloud webhooks: deliveries are HMAC-signed, so the inbox itself is public
+	var vendorCfg VendorWebhooksConfig
+	if cfg != nil {
+		vendorCfg = cfg.Vendor
+	}
+	if vendorCfg.Enabled {
+		http.HandleFunc(vendorWebhookPath, newVendorWebhookHandler(vendorCfg))
+	}
+	http.HandleFunc("/api/v1/integrations/webhooks", requireWebAuth(newVendorWebhookInboxHandler(vendorCfg)))
+	http.HandleFunc("/api/v1/integrations/readings", requireWebAuth(handleVendorReadings))
+	http.HandleFunc("/api/v1/integrations/webhook-secrets", requireWebAuth(handleVendorWebhookSecrets))
+	http.HandleFunc("/api/v1/metrics/archives", requireWebAuth(handleMetricsArchives))
+	http.HandleFunc("/api/v1/metrics/export", requireWebAuth(handleMetricsExport))
+	http.HandleFunc("/api/v1/metrics/archives/", requireWebAuth(handleMetricsArchiveRoute))
+
+	// Regional relays: admin registration plus the relay-token endpoints
+	http.HandleFunc("/api/v1/relays", requireWebAuth(handleRelays))
+	http.HandleFunc("/api/v1/relays/", requireWebAuth(handleRelay))
+	http.HandleFunc(relay.HeartbeatPath, requireRelayAuth(handleRelayHeartbeat))
+	http.HandleFunc(relay.IngestPath, requireRelayAuth(handleRelayIngest))
 	http.HandleFunc("/api/metrics/aggregated", requireWebAuth(handleMetricsAggregated))
 	http.HandleFunc("/api/metrics/timeseries", requireWebAuth(handleServerMetricsTimeSeries))
 	http.HandleFunc("/api/metrics/latest", requireWebAuth(handleServerMetricsLatest))
@@ -4053,6 +4607,22 @@ func handleAgentHeartbeat(w http.ResponseWriter, r *http.Request) {
 		Architecture    string `json:"architecture,omitempty"`
 		BuildType       string `json:"build_type,omitempty"`
 		GitCommit       string `json:"git_commit,omitempty"`
+		// Agent schedule - used to suggest staggered offsets
+		UploadIntervalSeconds    int `json:"upload_interval_seconds,omitempty"`
+		HeartbeatIntervalSeconds int `json:"heartbeat_interval_seconds,omitempty"`
+		// Agent can persist a rotated token from the response
+		TokenRotation bool `json:"token_rotation,omitempty"`
+		// Per-process nonce the agent must repeat to be sent a rotated token again
+		TokenRotationNonce string `json:"token_rotation_nonce,omitempty"`
+		// Public key the agent signs meter reads with
+		MeterReadPublicKey string `json:"meter_read_public_key,omitempty"`
+		// Settings the agent is running with, compared against policy for drift
+		EffectiveSettings *pmsettings.Settings `json:"effective_settings,omitempty"`
+		// Relay the agent currently uploads through (0 = direct)
+		RelayID    int64 `json:"relay_id,omitempty"`
+		RelayRTTMS int   `json:"relay_rtt_ms,omitempty"`
+		// Detected runtime: container, systemd, windows_service, service or interactive
+		RuntimeEnvironment string `json:"runtime_environment,omitempty"`
 	}
 
 	if err := decodeJSONBody(r, &req); err != nil {
@@ -4075,17 +4645,18 @@ func handleAgentHeartbeat(w http.ResponseWriter, r *http.Request) {
 	// Update agent using shared heartbeat logic
 	ctx := context.Background()
 	hbData := &storage.HeartbeatData{
-		Status:          req.Status,
-		Version:         req.Version,
-		ProtocolVersion: req.ProtocolVersion,
-		Hostname:        req.Hostname,
-		IP:              req.IP,
-		Platform:        req.Platform,
-		OSVersion:       req.OSVersion,
-		GoVersion:       req.GoVersion,
-		Architecture:    req.Architecture,
-		BuildType:       req.BuildType,
-		GitCommit:       req.GitCommit,
+		Status:             req.Status,
+		Version:            req.Version,
+		ProtocolVersion:    req.ProtocolVersion,
+		Hostname:           req.Hostname,
+		IP:                 req.IP,
+		Platform:           req.Platform,
+		OSVersion:          req.OSVersion,
+		GoVersion:          req.GoVersion,
+		Architecture:       req.Architecture,
+		BuildType:          req.BuildType,
+		GitCommit:          req.GitCommit,
+		RuntimeEnvironment: req.RuntimeEnvironment,
 	}
 
 	if agentUpdate := hbData.BuildAgentUpdate(agent.AgentID); agentUpdate != nil {
@@ -4109,9 +4680,18 @@ func handleAgentHeartbeat(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
+	recordAgentRelay(ctx, agent.AgentID, req.RelayID, req.RelayRTTMS)
+
+	clientIP := extractClientIP(r)
+	presentedToken := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	renewal, err := renewAgentToken(ctx, serverStore, agent, presentedToken, req.TokenRotationNonce, req.TokenRotation, clientIP)
+	if err != nil {
+		logWarn("Failed to rotate agent token", "agent_id", agent.AgentID, "error", err)
+	}
+	registerAgentSigningKey(ctx, agent, req.MeterReadPublicKey, clientIP)
+
 	// Log audit entry for heartbeat (only occasionally to reduce log volume)
 	// Could add logic here to only log every Nth heartbeat
-	clientIP := extractClientIP(r)
 	logAuditEntry(ctx, &storage.AuditEntry{
 		ActorType: storage.AuditActorAgent,
 		ActorID:   agent.AgentID,
@@ -4137,13 +4717,28 @@ func handleAgentHeartbeat(w http.ResponseWriter, r *http.Request) {
 	logDebug("Heartbeat received", "agent_id", agent.AgentID, "status", req.Status)
 
 	resp := map[string]interface{}{
-		"success": true,
+		"success":  true,
+		"schedule": agentScheduleHint(agent.AgentID, req.UploadIntervalSeconds, req.HeartbeatIntervalSeconds),
 	}
 	if snapshot.Version != "" {
 		resp["settings_version"] = snapshot.Version
 		if req.SettingsVersion != snapshot.Version {
 			resp["settings_snapshot"] = snapshot
 		}
+		// A re-enforced policy is re-sent even when the versions match
+		if drift := recordAgentConfigDrift(ctx, agent, snapshot, req.EffectiveSettings, req.SettingsVersion); drift != nil && drift.EnforceRequestedAt != nil {
+			resp["settings_snapshot"] = snapshot
+			resp["settings_enforce"] = true
+			markConfigDriftEnforced(ctx, drift)
+		}
+	}
+	if renewal != nil {
+		for key, value := range renewal.fields() {
+			resp[key] = value
+		}
+	}
+	if relays := currentRelayAssignments(ctx); len(relays) > 0 {
+		resp["relays"] = relays
 	}
 
 	w.Header().Set("Content-Type", "application/json")
@@ -4379,7 +4974,8 @@ func handleAgentsList(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(resp)
 }
 
-// handleAgentCommand sends a command to an agent via WebSocket
+// handleAgentCommand sends a command to an agent via WebSocket, or via the
+// MQTT bridge for agents on the MQTT transport
 // POST /api/v1/agents/command/{agentID}
 // Body: {"command": "check_update" | "restart" | ...}
 func handleAgentCommand(w http.ResponseWriter, r *http.Request) {
@@ -4436,36 +5032,20 @@ func handleAgentCommand(w http.ResponseWriter, r *http.Request) {
 	if !authorizeOrReject(w, r, authz.ActionAgentsWrite, authz.ResourceRef{TenantIDs: []string{agent.TenantID}}) {
 		return
 	}
-
-	// Check if agent is connected via WebSocket
-	conn, connected := getAgentWSConnection(agentID)
-	if !connected {
-		http.Error(w, "Agent not connected via WebSocket", http.StatusServiceUnavailable)
+	if req.Command == "force_update" && approvalsRequired() {
+		approval := agentApproval(approvalActionAgentForceUpdate, agent,
+			fmt.Sprintf("Force reinstall of %s", displayNameForAgent(agent)))
+		approval.Reason = getCommandStringField(req.Data, "reason")
+		approval.Payload = map[string]interface{}{"data": req.Data}
+		holdForApproval(w, r, approval)
 		return
 	}
 
-	// Send command via WebSocket
-	msg := wscommon.Message{
-		Type:      wscommon.MessageTypeCommand,
-		Timestamp: time.Now(),
-		Data: map[string]interface{}{
-			"command": req.Command,
-		},
-	}
-	if req.Data != nil {
-		for k, v := range req.Data {
-			msg.Data[k] = v
+	if err := sendAgentCommand(agentID, req.Command, req.Data); err != nil {
+		if errors.Is(err, errAgentNotConnected) {
+			http.Error(w, "Agent not connected", http.StatusServiceUnavailable)
+			return
 		}
-	}
-
-	payload, err := json.Marshal(msg)
-	if err != nil {
-		logError("Failed to marshal command message", "error", err)
-		http.Error(w, "Internal error", http.StatusInternalServerError)
-		return
-	}
-
-	if err := conn.WriteRaw(payload, 10*time.Second); err != nil {
 		logWarn("Failed to send command to agent", "agent_id", agentID, "command", req.Command, "error", err)
 		http.Error(w, "Failed to send command", http.StatusInternalServerError)
 		return
@@ -4673,9 +5253,12 @@ func handleAgentDetails(w http.ResponseWriter, r *http.Request) {
 			if !authorizeOrReject(w, r, authz.ActionAgentsDelete, authz.ResourceRef{TenantIDs: []string{agent.TenantID}}) {
 				return
 			}
-			// Delete agent and all associated data
-			err = serverStore.DeleteAgent(ctx, agentID)
-			if err != nil {
+			if approvalsRequired() {
+				holdForApproval(w, r, agentApproval(approvalActionAgentDelete, agent,
+					fmt.Sprintf("Delete agent %s and all its data", displayNameForAgent(agent))))
+				return
+			}
+			if err := removeAgent(ctx, agentID); err != nil {
 				logError("Failed to delete agent", "agent_id", agentID, "error", err)
 				if err.Error() == "agent not found" {
 					http.Error(w, "Agent not found", http.StatusNotFound)
@@ -4685,22 +5268,6 @@ func handleAgentDetails(w http.ResponseWriter, r *http.Request) {
 				return
 			}
 
-			// Close WebSocket connection if active
-			closeAgentWebSocket(agentID)
-
-			// Clean up diagnostic counters for this agent to prevent memory leak
-			cleanupAgentDiagnostics(agentID)
-
-			// Broadcast agent_deleted event to UI via SSE
-			sseHub.Broadcast(SSEEvent{
-				Type: "agent_deleted",
-				Data: map[string]interface{}{
-					"agent_id": agentID,
-				},
-			})
-
-			logInfo("Agent deleted", "agent_id", agentID)
-
 			w.Header().Set("Content-Type", "application/json")
 			json.NewEncoder(w).Encode(map[string]string{
 				"message": "Agent deleted successfully",
@@ -4713,6 +5280,31 @@ func handleAgentDetails(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// removeAgent deletes an agent with all associated data and drops its live
+// connection.
+func removeAgent(ctx context.Context, agentID string) error {
+	if err := serverStore.DeleteAgent(ctx, agentID); err != nil {
+		return err
+	}
+
+	// Close WebSocket connection if active
+	closeAgentWebSocket(agentID)
+
+	// Clean up diagnostic counters for this agent to prevent memory leak
+	cleanupAgentDiagnostics(agentID)
+
+	// Broadcast agent_deleted event to UI via SSE
+	sseHub.Broadcast(SSEEvent{
+		Type: "agent_deleted",
+		Data: map[string]interface{}{
+			"agent_id": agentID,
+		},
+	})
+
+	logInfo("Agent deleted", "agent_id", agentID)
+	return nil
+}
+
 // handleAgentProxy proxies HTTP requests to the agent's own web UI through WebSocket
 func handleAgentProxy(w http.ResponseWriter, r *http.Request) {
 	// Extract agent ID from path: /api/v1/proxy/agent/{agentID}/{path...}
@@ -4765,6 +5357,25 @@ func handleAgentProxy(w http.ResponseWriter, r *http.Request) {
 	if !authorizeOrReject(w, r, authz.ActionProxyAgentConnect, authz.ResourceRef{TenantIDs: []string{agent.TenantID}}) {
 		return
 	}
+	if !tenantFeatureEnabled(ctx, agent.TenantID, pmsettings.FeatureRemoteProxy) {
+		http.Error(w, "remote proxy is disabled for this tenant", http.StatusForbidden)
+		return
+	}
+
+	// Endpoints that wipe agent data wait for a second administrator
+	agentPath, _, _ := strings.Cut(targetPath, "?")
+	if gate, gated := gatedAgentProxyPaths[agentPath]; gated && r.Method == http.MethodPost && approvalsRequired() {
+		approval, err := requestActionApproval(r, agentApproval(gate.Action, agent,
+			fmt.Sprintf(gate.Summary, displayNameForAgent(agent))))
+		if err != nil {
+			logError("Failed to record action approval", "action", gate.Action, "agent_id", agentID, "error", err)
+			http.Error(w, "failed to request approval", http.StatusInternalServerError)
+			return
+		}
+		// The agent UI shows non-2xx bodies to the user, so explain the hold there
+		http.Error(w, fmt.Sprintf("approval required: request #%d is waiting for a second administrator", approval.ID), http.StatusForbidden)
+		return
+	}
 
 	// Build target URL for agent's local web UI
 	// Agents typically run on http://localhost:8080
@@ -4777,6 +5388,21 @@ func handleAgentProxy(w http.ResponseWriter, r *http.Request) {
 }
 
 // handleDeviceProxy proxies HTTP requests to device web UIs through agent WebSocket
+// tenantFeatureEnabled resolves a feature flag for a tenant (or the server
+// default when tenantID is empty). Lookup failures fall back to the flag's
+// built-in default.
+func tenantFeatureEnabled(ctx context.Context, tenantID, key string) bool {
+	if settingsResolver == nil {
+		return pmsettings.FeatureEnabled(nil, key)
+	}
+	snap, err := settingsResolver.ResolveFeatureFlags(ctx, tenantID)
+	if err != nil {
+		logWarn("Feature flag lookup failed", "tenant_id", tenantID, "flag", key, "error", err)
+		return pmsettings.FeatureEnabled(nil, key)
+	}
+	return pmsettings.FeatureEnabled(snap.Values(), key)
+}
+
 func handleDeviceProxy(w http.ResponseWriter, r *http.Request) {
 	serial, targetPath, err := parseDeviceProxyPath(r.URL.Path, "/api/v1/proxy/device/")
 	if err != nil {
@@ -5011,6 +5637,10 @@ func proxyDeviceRequest(w http.ResponseWriter, r *http.Request, serial string, t
 	if !authorizeOrReject(w, r, authz.ActionProxyDeviceConnect, authz.ResourceRef{TenantIDs: []string{agent.TenantID}}) {
 		return
 	}
+	if !tenantFeatureEnabled(ctx, agent.TenantID, pmsettings.FeatureRemoteProxy) {
+		http.Error(w, "remote proxy is disabled for this tenant", http.StatusForbidden)
+		return
+	}
 
 	// Proxy to the AGENT's local device proxy endpoint, not directly to the device.
 	// The agent's /proxy/{serial}/... endpoint handles all the complex stuff:
@@ -5125,13 +5755,7 @@ func proxyThroughWebSocketWithTimeout(w http.ResponseWriter, r *http.Request, ag
 		}
 	}
 
-	// Add principal info for the agent to use when returning auth/me responses
-	// This allows the proxied agent UI to know who the server-authenticated user is
-	principal := getPrincipal(r)
-	if principal != nil && principal.User != nil {
-		headers["X-PrintMaster-User"] = principal.User.Username
-		headers["X-PrintMaster-Role"] = string(principal.Role)
-	}
+	addProxyPrincipalHeaders(r, headers)
 
 	logTraceTag("proxy", "Proxy request dispatched",
 		"agent_id", agentID,
@@ -5828,6 +6452,17 @@ func handleDeviceReportProxy(w http.ResponseWriter, r *http.Request) {
 	proxyReportWithServerLogs(w, r, device.AgentID, agentURL)
 }
 
+// addProxyPrincipalHeaders tells the agent who the server-authenticated user is.
+// The agent shows this user in its proxied UI and enforces the role on every
+// proxied request.
+func addProxyPrincipalHeaders(r *http.Request, headers map[string]string) {
+	principal := getPrincipal(r)
+	if principal != nil && principal.User != nil {
+		headers["X-PrintMaster-User"] = principal.User.Username
+		headers["X-PrintMaster-Role"] = string(storage.AgentRole(principal.Role))
+	}
+}
+
 // proxyReportWithServerLogs proxies a report request to the agent and injects server logs
 func proxyReportWithServerLogs(w http.ResponseWriter, r *http.Request, agentID string, targetURL string) {
 	timeout := 60 * time.Second // Reports can take a while with full SNMP walks
@@ -5865,6 +6500,8 @@ func proxyReportWithServerLogs(w http.ResponseWriter, r *http.Request, agentID s
 		}
 	}
 
+	addProxyPrincipalHeaders(r, headers)
+
 	// Send proxy request to agent
 	if err := sendProxyRequest(agentID, requestID, targetURL, r.Method, headers, bodyStr); err != nil {
 		logError("Failed to send report proxy request", "agent_id", agentID, "error", err)
@@ -6342,8 +6979,12 @@ func handleDevicesBatch(w http.ResponseWriter, r *http.Request) {
 
 	logInfo("Devices batch received", "agent_id", req.AgentID, "count", len(req.Devices))
 
+	// Get authenticated agent from context
+	agent := r.Context().Value(agentContextKey).(*storage.Agent)
+
 	// Store each device
 	ctx := context.Background()
+	approvals := newDeviceApprovalGate(ctx, serverStore, agent)
 	stored := 0
 	for _, deviceMap := range req.Devices {
 		// Convert map to Device struct (simplified - in production, use proper unmarshaling)
@@ -6410,6 +7051,8 @@ func handleDevicesBatch(w http.ResponseWriter, r *http.Request) {
 			continue
 		}
 
+		approvals.Record(ctx, device)
+
 		if err := serverStore.UpsertDevice(ctx, device); err != nil {
 			logError("Failed to store device", "serial", device.Serial, "error", err)
 			continue
@@ -6430,9 +7073,6 @@ func handleDevicesBatch(w http.ResponseWriter, r *http.Request) {
 		})
 	}
 
-	// Get authenticated agent from context
-	agent := r.Context().Value(agentContextKey).(*storage.Agent)
-
 	logInfo("Devices stored", "agent_id", agent.AgentID, "stored", stored, "total", len(req.Devices))
 
 	// Log audit entry for device upload
@@ -6608,7 +7248,7 @@ func enrichDevicesWithMetrics(ctx context.Context, devices []*storage.Device) []
 		if d == nil {
 			continue
 		}
-		enriched := &storage.DeviceWithMetrics{Device: *d}
+		enriched := &storage.DeviceWithMetrics{Device: *d, StatusState: string(deviceStatusState(d))}
 		if m, ok := metricsMap[d.Serial]; ok && m != nil {
 			enriched.TonerLevels = m.TonerLevels
 			enriched.PageCount = m.PageCount
@@ -6696,6 +7336,7 @@ type DashboardDeviceSummary struct {
 	Model        string    `json:"model"`
 	IP           string    `json:"ip"`
 	Status       string    `json:"status"`
+	State        string    `json:"state"`         // Canonical state: ready, warning, error, offline, maintenance
 	LowestSupply int       `json:"lowest_supply"` // 0-100 percentage
 	SupplyStatus string    `json:"supply_status"` // critical, low, medium, high, unknown
 	PageCount    int       `json:"page_count"`
@@ -7026,6 +7667,7 @@ func buildDashboardAgent(a *storage.Agent, agentDevices []*storage.DeviceWithMet
 				Model:        d.Model,
 				IP:           d.IP,
 				Status:       deriveDeviceStatus(&d.Device),
+				State:        string(deviceStatusState(&d.Device)),
 				LowestSupply: lowest,
 				SupplyStatus: deriveSupplyStatus(lowest),
 				PageCount:    d.PageCount,
@@ -7085,7 +7727,9 @@ func deriveSupplyStatus(level int) string {
 	return "high"
 }
 
-// deriveDeviceStatus returns a status string based on device state
+// deriveDeviceStatus returns the dashboard status badge for a device. The
+// canonical state comes from the shared device status rules; jams are
+// surfaced separately so they can be filtered on their own.
 func deriveDeviceStatus(d *storage.Device) string {
 	if d == nil {
 		return "unknown"
@@ -7099,8 +7743,15 @@ func deriveDeviceStatus(d *storage.Device) string {
 			return "jam"
 		}
 	}
-	// Default to healthy for now - more sophisticated status could be derived
-	// from printer-specific OIDs
+	if devicestatus.HasJam(d.StatusMessages) {
+		return "jam"
+	}
+	switch deviceStatusState(d) {
+	case devicestatus.StateError, devicestatus.StateOffline:
+		return "error"
+	case devicestatus.StateWarning, devicestatus.StateMaintenance:
+		return "warning"
+	}
 	return "healthy"
 }
 
@@ -7725,6 +8376,15 @@ func handleMetricsBatch(w http.ResponseWriter, r *http.Request) {
 		if v, ok := metricMap["scan_count"].(float64); ok {
 			metric.ScanCount = int(v)
 		}
+		if v, ok := metricMap["duplex_sheets"].(float64); ok {
+			metric.DuplexSheets = int(v)
+		}
+		if v, ok := metricMap["simplex_sheets"].(float64); ok {
+			metric.SimplexSheets = int(v)
+		}
+		if v, ok := metricMap["nup_sheets"].(float64); ok {
+			metric.NUpSheets = int(v)
+		}
 		if v, ok := metricMap["toner_levels"].(map[string]interface{}); ok {
 			metric.TonerLevels = v
 		}
@@ -8253,6 +8913,14 @@ func handleAuditLogs(w http.ResponseWriter, r *http.Request) {
 		since = parsed
 	}
 
+	// Non-admins (tenant admins, auditors) only see entries from their tenants
+	scope, ok := tenantScope(getPrincipal(r))
+	if !ok {
+		http.Error(w, "forbidden", http.StatusForbidden)
+		return
+	}
+	allTenants := scope == nil
+
 	// Parse pagination params
 	limitStr := query.Get("limit")
 	offsetStr := query.Get("offset")
@@ -8271,20 +8939,42 @@ func handleAuditLogs(w http.ResponseWriter, r *http.Request) {
 			offset = 0
 		}
 
-		// Get total count
-		totalCount, err := serverStore.CountAuditLog(r.Context(), actorID, since)
-		if err != nil {
-			logError("Failed to count audit log", "actor_id", actorID, "error", err)
-			http.Error(w, "failed to count audit log", http.StatusInternalServerError)
-			return
-		}
+		var entries []*storage.AuditEntry
+		var totalCount int64
+		if allTenants {
+			// Get total count
+			count, err := serverStore.CountAuditLog(r.Context(), actorID, since)
+			if err != nil {
+				logError("Failed to count audit log", "actor_id", actorID, "error", err)
+				http.Error(w, "failed to count audit log", http.StatusInternalServerError)
+				return
+			}
+			totalCount = count
 
-		// Get paginated entries
-		entries, err := serverStore.GetAuditLogPaginated(r.Context(), actorID, since, limit, offset)
-		if err != nil {
-			logError("Failed to load audit log", "actor_id", actorID, "error", err)
-			http.Error(w, "failed to load audit log", http.StatusInternalServerError)
-			return
+			// Get paginated entries
+			entries, err = serverStore.GetAuditLogPaginated(r.Context(), actorID, since, limit, offset)
+			if err != nil {
+				logError("Failed to load audit log", "actor_id", actorID, "error", err)
+				http.Error(w, "failed to load audit log", http.StatusInternalServerError)
+				return
+			}
+		} else {
+			// Tenant filtering happens here, so paginate the filtered list
+			all, err := serverStore.GetAuditLog(r.Context(), actorID, since)
+			if err != nil {
+				logError("Failed to load audit log", "actor_id", actorID, "error", err)
+				http.Error(w, "failed to load audit log", http.StatusInternalServerError)
+				return
+			}
+			all = filterAuditEntriesByTenant(all, scope)
+			totalCount = int64(len(all))
+			if offset < len(all) {
+				end := offset + limit
+				if end > len(all) {
+					end = len(all)
+				}
+				entries = all[offset:end]
+			}
 		}
 
 		hasMore := int64(offset+len(entries)) < totalCount
@@ -8317,6 +9007,9 @@ func handleAuditLogs(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "failed to load audit log", http.StatusInternalServerError)
 		return
 	}
+	if !allTenants {
+		entries = filterAuditEntriesByTenant(entries, scope)
+	}
 
 	response := map[string]interface{}{
 		"entries":  entries,
@@ -8334,6 +9027,21 @@ func handleAuditLogs(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// filterAuditEntriesByTenant keeps the entries recorded against a tenant in
+// scope. Entries without a tenant are global and are dropped.
+func filterAuditEntriesByTenant(entries []*storage.AuditEntry, scope map[string]struct{}) []*storage.AuditEntry {
+	filtered := make([]*storage.AuditEntry, 0, len(entries))
+	for _, entry := range entries {
+		if entry == nil {
+			continue
+		}
+		if _, ok := scope[entry.TenantID]; ok {
+			filtered = append(filtered, entry)
+		}
+	}
+	return filtered
+}
+
 // ===== Server Settings API =====
 
 type serverSettingsRequest struct {
@@ -8361,6 +9069,11 @@ type serverSettingsSecuritySection struct {
 	RateLimitMaxAttempts   *int  `json:"rate_limit_max_attempts"`
 	RateLimitBlockMinutes  *int  `json:"rate_limit_block_minutes"`
 	RateLimitWindowMinutes *int  `json:"rate_limit_window_minutes"`
+	SessionLifetimeHours   *int  `json:"session_lifetime_hours"`
+	SessionIdleMinutes     *int  `json:"session_idle_minutes"`
+	AgentTokenLifetimeDays *int  `json:"agent_token_lifetime_days"`
+	RequireApprovals       *bool `json:"require_approvals"`
+	ApprovalExpiryHours    *int  `json:"approval_expiry_hours"`
 }
 
 type serverSettingsTLSSection struct {
@@ -8540,6 +9253,11 @@ func buildServerSettingsResponse(cfg *Config) map[string]interface{} {
 			"rate_limit_max_attempts":   cfg.Security.RateLimitMaxAttempts,
 			"rate_limit_block_minutes":  cfg.Security.RateLimitBlockMinutes,
 			"rate_limit_window_minutes": cfg.Security.RateLimitWindowMinutes,
+			"session_lifetime_hours":    cfg.Security.SessionLifetimeHours,
+			"session_idle_minutes":      cfg.Security.SessionIdleMinutes,
+			"agent_token_lifetime_days": cfg.Security.AgentTokenLifetimeDays,
+			"require_approvals":         cfg.Security.RequireApprovals,
+			"approval_expiry_hours":     cfg.Security.ApprovalExpiryHours,
 		},
 		"tls": map[string]interface{}{
 			"mode":                   cfg.TLS.Mode,
@@ -8575,6 +9293,11 @@ func buildServerSettingsResponse(cfg *Config) map[string]interface{} {
 			"max_artifacts":          cfg.SelfUpdate.MaxArtifacts,
 			"check_interval_minutes": cfg.SelfUpdate.CheckIntervalMinutes,
 		},
+		"ingest": map[string]interface{}{
+			"workers":          cfg.Ingest.Workers,
+			"queue_size":       cfg.Ingest.QueueSize,
+			"max_wait_seconds": cfg.Ingest.MaxWaitSeconds,
+		},
 	}
 }
 
@@ -8841,6 +9564,72 @@ func applyServerSettings(cfg *Config, req *serverSettingsRequest) (*serverSettin
 				markChanged("security.rate_limit_window_minutes", true)
 			}
 		}
+		if section.SessionLifetimeHours != nil {
+			if err := ensureConfigKeyEditable("security.session_lifetime_hours"); err != nil {
+				*cfg = original
+				return nil, err
+			}
+			if *section.SessionLifetimeHours <= 0 {
+				*cfg = original
+				return nil, fmt.Errorf("security.session_lifetime_hours must be positive")
+			}
+			if cfg.Security.SessionLifetimeHours != *section.SessionLifetimeHours {
+				cfg.Security.SessionLifetimeHours = *section.SessionLifetimeHours
+				markChanged("security.session_lifetime_hours", false)
+			}
+		}
+		if section.SessionIdleMinutes != nil {
+			if err := ensureConfigKeyEditable("security.session_idle_minutes"); err != nil {
+				*cfg = original
+				return nil, err
+			}
+			if *section.SessionIdleMinutes < 0 {
+				*cfg = original
+				return nil, fmt.Errorf("security.session_idle_minutes cannot be negative")
+			}
+			if cfg.Security.SessionIdleMinutes != *section.SessionIdleMinutes {
+				cfg.Security.SessionIdleMinutes = *section.SessionIdleMinutes
+				markChanged("security.session_idle_minutes", false)
+			}
+		}
+		if section.AgentTokenLifetimeDays != nil {
+			if err := ensureConfigKeyEditable("security.agent_token_lifetime_days"); err != nil {
+				*cfg = original
+				return nil, err
+			}
+			if *section.AgentTokenLifetimeDays <= 0 {
+				*cfg = original
+				return nil, fmt.Errorf("security.agent_token_lifetime_days must be positive")
+			}
+			if cfg.Security.AgentTokenLifetimeDays != *section.AgentTokenLifetimeDays {
+				cfg.Security.AgentTokenLifetimeDays = *section.AgentTokenLifetimeDays
+				markChanged("security.agent_token_lifetime_days", false)
+			}
+		}
+		if section.RequireApprovals != nil {
+			if err := ensureConfigKeyEditable("security.require_approvals"); err != nil {
+				*cfg = original
+				return nil, err
+			}
+			if cfg.Security.RequireApprovals != *section.RequireApprovals {
+				cfg.Security.RequireApprovals = *section.RequireApprovals
+				markChanged("security.require_approvals", false)
+			}
+		}
+		if section.ApprovalExpiryHours != nil {
+			if err := ensureConfigKeyEditable("security.approval_expiry_hours"); err != nil {
+				*cfg = original
+				return nil, err
+			}
+			if *section.ApprovalExpiryHours <= 0 {
+				*cfg = original
+				return nil, fmt.Errorf("security.approval_expiry_hours must be positive")
+			}
+			if cfg.Security.ApprovalExpiryHours != *section.ApprovalExpiryHours {
+				cfg.Security.ApprovalExpiryHours = *section.ApprovalExpiryHours
+				markChanged("security.approval_expiry_hours", false)
+			}
+		}
 	}
 
 	if section := req.TLS; section != nil {
@@ -9209,6 +9998,17 @@ func handleAgentUpdateManifest(w http.ResponseWriter, r *http.Request) {
 		"arch", req.Arch,
 		"channel", req.Channel)
 
+	if agent, ok := r.Context().Value(agentContextKey).(*storage.Agent); ok && agent != nil {
+		if !tenantFeatureEnabled(r.Context(), agent.TenantID, pmsettings.FeatureFirmwareUpdates) {
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"success": false,
+				"message": "updates are disabled for this tenant",
+			})
+			return
+		}
+	}
+
 	// Fetch matching manifest from release manager
 	if releaseManager == nil {
 		w.Header().Set("Content-Type", "application/json")
@@ -9328,15 +10128,16 @@ func handleAgentUpdateTelemetry(w http.ResponseWriter, r *http.Request) {
 	}
 
 	var req struct {
-		AgentID        string                 `json:"agent_id"`
-		RunID          string                 `json:"run_id,omitempty"`
-		Status         string                 `json:"status"`
-		CurrentVersion string                 `json:"current_version"`
-		TargetVersion  string                 `json:"target_version,omitempty"`
-		ErrorCode      string                 `json:"error_code,omitempty"`
-		ErrorMessage   string                 `json:"error_message,omitempty"`
-		Timestamp      time.Time              `json:"timestamp"`
-		Metadata       map[string]interface{} `json:"metadata,omitempty"`
+		AgentID        string                      `json:"agent_id"`
+		RunID          string                      `json:"run_id,omitempty"`
+		Status         string                      `json:"status"`
+		CurrentVersion string                      `json:"current_version"`
+		TargetVersion  string                      `json:"target_version,omitempty"`
+		ErrorCode      string                      `json:"error_code,omitempty"`
+		ErrorMessage   string                      `json:"error_message,omitempty"`
+		Timestamp      time.Time                   `json:"timestamp"`
+		Metadata       map[string]interface{}      `json:"metadata,omitempty"`
+		Diagnostics    *storage.FailureDiagnostics `json:"diagnostics,omitempty"`
 	}
 	if err := decodeJSONBody(r, &req); err != nil {
 		http.Error(w, "invalid request body", http.StatusBadRequest)
@@ -9352,7 +10153,41 @@ func handleAgentUpdateTelemetry(w http.ResponseWriter, r *http.Request) {
 		"error_code", req.ErrorCode,
 	)
 
-	// TODO: Store telemetry for dashboards/reporting
+	// Keep the rollout record for the run; reports without a run ID (older
+	// agents, validation failures from before run IDs were persisted) are
+	// grouped per target version.
+	if agent, ok := r.Context().Value(agentContextKey).(*storage.Agent); ok && agent != nil && req.Status != "" {
+		runID := strings.TrimSpace(req.RunID)
+		if runID == "" {
+			runID = "untracked-" + req.TargetVersion
+		}
+		run := &storage.AgentUpdateRun{
+			AgentID:        agent.AgentID,
+			TenantID:       agent.TenantID,
+			RunID:          runID,
+			Status:         req.Status,
+			CurrentVersion: req.CurrentVersion,
+			TargetVersion:  req.TargetVersion,
+			ErrorCode:      req.ErrorCode,
+			ErrorMessage:   req.ErrorMessage,
+			Metadata:       req.Metadata,
+		}
+		if req.Status == storage.AgentUpdateStatusFailed {
+			run.ErrorSignature = updateErrorSignature(req.ErrorCode, req.ErrorMessage)
+			trimUpdateDiagnostics(req.Diagnostics)
+			run.Diagnostics = req.Diagnostics
+			logWarn("Agent update failed",
+				"agent_id", agent.AgentID,
+				"run_id", runID,
+				"target_version", req.TargetVersion,
+				"signature", run.ErrorSignature,
+				"diagnostics", req.Diagnostics != nil,
+			)
+		}
+		if err := serverStore.RecordAgentUpdateRun(r.Context(), run); err != nil {
+			logError("Failed to record agent update run", "agent_id", agent.AgentID, "run_id", runID, "error", err)
+		}
+	}
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]interface{}{
@@ -9364,7 +10199,8 @@ func handleAgentUpdateTelemetry(w http.ResponseWriter, r *http.Request) {
 var releaseSyncMu sync.Mutex
 var releaseSyncInProgress bool
 
-// handleReleasesSync triggers an immediate sync of release artifacts from GitHub.
+// handleReleasesSync triggers an immediate sync of release artifacts from GitHub,
+// or an import of the configured bundle directory when releases are offline.
 // The sync runs in the background and progress is reported via SSE events.
 func handleReleasesSync(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
@@ -9446,6 +10282,110 @@ func handleReleasesSync(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
+// maxReleaseBundleBytes caps uploaded offline release bundles.
+const maxReleaseBundleBytes = 2 << 30
+
+// handleReleasesImport caches release artifacts from an uploaded offline
+// bundle (.zip, .tar.gz or .tgz) so air-gapped servers can update agents.
+func handleReleasesImport(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !authorizeOrReject(w, r, authz.ActionReleasesWrite, authz.ResourceRef{}) {
+		return
+	}
+	if intakeWorker == nil {
+		http.Error(w, "release intake worker not initialized", http.StatusServiceUnavailable)
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxReleaseBundleBytes)
+	reader, err := r.MultipartReader()
+	if err != nil {
+		http.Error(w, "expected multipart upload with a bundle file", http.StatusBadRequest)
+		return
+	}
+	var bundlePath, bundleName string
+	for {
+		part, perr := reader.NextPart()
+		if perr == io.EOF {
+			break
+		}
+		if perr != nil {
+			http.Error(w, "failed to read upload: "+perr.Error(), http.StatusBadRequest)
+			return
+		}
+		if part.FormName() != "bundle" || bundlePath != "" {
+			part.Close()
+			continue
+		}
+		bundleName = filepath.Base(part.FileName())
+		if !releases.IsBundleArchive(bundleName) {
+			part.Close()
+			http.Error(w, "bundle must be a .zip, .tar.gz or .tgz archive", http.StatusBadRequest)
+			return
+		}
+		dir, derr := os.MkdirTemp("", "printmaster-bundle-*")
+		if derr != nil {
+			part.Close()
+			http.Error(w, "failed to stage bundle", http.StatusInternalServerError)
+			return
+		}
+		defer os.RemoveAll(dir)
+		bundlePath = filepath.Join(dir, bundleName)
+		out, ferr := os.Create(bundlePath)
+		if ferr == nil {
+			_, ferr = io.Copy(out, part)
+			if cerr := out.Close(); ferr == nil {
+				ferr = cerr
+			}
+		}
+		part.Close()
+		if ferr != nil {
+			http.Error(w, "failed to receive bundle: "+ferr.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+	if bundlePath == "" {
+		http.Error(w, "missing bundle file", http.StatusBadRequest)
+		return
+	}
+
+	result, err := intakeWorker.ImportBundle(r.Context(), bundlePath)
+	if err != nil {
+		logWarn("Release bundle import failed", "bundle", bundleName, "error", err)
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": false,
+			"error":   err.Error(),
+		})
+		return
+	}
+
+	actorType, actorID, actorName, actorTenant := auditActorFromPrincipal(r)
+	logAuditEntry(r.Context(), &storage.AuditEntry{
+		ActorType: actorType,
+		ActorID:   actorID,
+		ActorName: actorName,
+		TenantID:  actorTenant,
+		Action:    "releases.import",
+		Details:   fmt.Sprintf("imported %d artifacts from %s (%d skipped)", len(result.Imported), bundleName, len(result.Skipped)),
+		IPAddress: extractClientIP(r),
+		UserAgent: r.Header.Get("User-Agent"),
+		Severity:  storage.AuditSeverityInfo,
+	})
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success":  true,
+		"source":   result.Source,
+		"imported": result.Imported,
+		"skipped":  result.Skipped,
+	})
+}
+
 // handleReleasesArtifacts lists cached release artifacts.
 func handleReleasesArtifacts(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
@@ -6,6 +6,7 @@ import (
 	"database/sql"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"net"
 	"net/http"
 	"net/http/httptest"
@@ -18,6 +19,7 @@ import (
 	"printmaster/server/storage"
 	"printmaster/server/tenancy"
 	"strconv"
+	"strings"
 	"sync"
 	"testing"
 	"time"
@@ -51,6 +53,8 @@ func setupTestServer(t *testing.T) (*httptest.Server, storage.Store) {
 		ProtocolVersion: ProtocolVersion,
 		ProcessStart:    processStart,
 		TenancyChecker:  tenancy.IsEnabled,
+		ReadinessChecks: readinessChecks(),
+		LivenessChecks:  livenessChecks(),
 	})
 	healthAPI.RegisterRoutes(mux)
 
@@ -160,6 +164,45 @@ func TestHealthEndpoint(t *testing.T) {
 	}
 }
 
+func TestReadyzEndpoint(t *testing.T) {
+	t.Parallel()
+
+	server, _ := setupTestServer(t)
+
+	resp, err := http.Get(server.URL + "/readyz")
+	if err != nil {
+		t.Fatalf("Failed to call /readyz: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", resp.StatusCode)
+	}
+
+	var result struct {
+		Status string                 `json:"status"`
+		Checks []handlers.CheckResult `json:"checks"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	statuses := make(map[string]string)
+	for _, check := range result.Checks {
+		statuses[check.Name] = check.Status
+	}
+	if statuses["database"] != "ok" || statuses["migrations"] != "ok" {
+		t.Fatalf("expected database and migrations to pass, got %+v", result.Checks)
+	}
+
+	live, err := http.Get(server.URL + "/livez")
+	if err != nil {
+		t.Fatalf("Failed to call /livez: %v", err)
+	}
+	live.Body.Close()
+	if live.StatusCode != http.StatusOK {
+		t.Fatalf("Expected /livez status 200, got %d", live.StatusCode)
+	}
+}
+
 func TestRunHealthCheckHTTP(t *testing.T) {
 	t.Parallel()
 
@@ -417,6 +460,13 @@ func TestHeartbeatWithValidToken(t *testing.T) {
 	if result["success"] != true {
 		t.Errorf("Expected success=true, got %v", result["success"])
 	}
+	schedule, ok := result["schedule"].(map[string]interface{})
+	if !ok {
+		t.Fatalf("Expected schedule hint in response, got %v", result["schedule"])
+	}
+	if offset, _ := schedule["upload_offset_seconds"].(float64); offset < 0 || offset >= defaultAgentUploadIntervalSeconds {
+		t.Errorf("upload_offset_seconds out of range: %v", schedule["upload_offset_seconds"])
+	}
 }
 
 func TestHeartbeatReturnsSettingsSnapshotWhenVersionDiffers(t *testing.T) {
@@ -566,6 +616,87 @@ func TestHandleUsersEnforcesAdmin(t *testing.T) {
 	}
 }
 
+func TestTenantAdminManagesOnlyTenantUsers(t *testing.T) {
+	store := SetupTestStore(t)
+	ctx := context.Background()
+	for _, id := range []string{"tenant-a", "tenant-b"} {
+		if err := store.CreateTenant(ctx, &storage.Tenant{ID: id, Name: id}); err != nil {
+			t.Fatalf("failed to seed tenant: %v", err)
+		}
+	}
+	inScope := &storage.User{Username: "alice", Role: storage.RoleViewer, TenantID: "tenant-a", TenantIDs: []string{"tenant-a"}}
+	outOfScope := &storage.User{Username: "bob", Role: storage.RoleViewer, TenantID: "tenant-b", TenantIDs: []string{"tenant-b"}}
+	for _, u := range []*storage.User{inScope, outOfScope, NewTestAdminUser()} {
+		if err := store.CreateUser(ctx, u, "secret-password"); err != nil {
+			t.Fatalf("failed to seed user: %v", err)
+		}
+	}
+	tenantAdmin := NewTestUser(storage.RoleTenantAdmin, "tenant-a")
+	do := func(method, target, body string) *httptest.ResponseRecorder {
+		req := InjectTestUser(httptest.NewRequest(method, target, strings.NewReader(body)), tenantAdmin)
+		rec := httptest.NewRecorder()
+		if target == "/api/v1/users" {
+			handleUsers(rec, req)
+		} else {
+			handleUser(rec, req)
+		}
+		return rec
+	}
+
+	rec := do(http.MethodGet, "/api/v1/users", "")
+	var listed []storage.User
+	if err := json.Unmarshal(rec.Body.Bytes(), &listed); err != nil || len(listed) != 1 || listed[0].Username != "alice" {
+		t.Fatalf("expected only alice, got %d %s", rec.Code, rec.Body.String())
+	}
+	if rec := do(http.MethodGet, fmt.Sprintf("/api/v1/users/%d", outOfScope.ID), ""); rec.Code != http.StatusNotFound {
+		t.Fatalf("expected 404 for another tenant's user, got %d", rec.Code)
+	}
+	if rec := do(http.MethodPut, fmt.Sprintf("/api/v1/users/%d", inScope.ID), `{"role":"admin","tenant_ids":["tenant-a"]}`); rec.Code != http.StatusForbidden {
+		t.Fatalf("expected 403 promoting to admin, got %d", rec.Code)
+	}
+	if rec := do(http.MethodPut, fmt.Sprintf("/api/v1/users/%d", inScope.ID), `{"role":"operator","tenant_ids":["tenant-a","tenant-b"]}`); rec.Code != http.StatusForbidden {
+		t.Fatalf("expected 403 granting another tenant, got %d", rec.Code)
+	}
+	if rec := do(http.MethodPost, "/api/v1/users", `{"username":"carol","password":"secret-password","role":"auditor"}`); rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 creating a global user, got %d", rec.Code)
+	}
+	if rec := do(http.MethodPost, "/api/v1/users", `{"username":"carol","password":"secret-password","role":"auditor","tenant_ids":["tenant-a"]}`); rec.Code != http.StatusCreated {
+		t.Fatalf("expected 201 creating an in-scope auditor, got %d %s", rec.Code, rec.Body.String())
+	}
+}
+
+func TestAuditLogScopedToTenants(t *testing.T) {
+	store := SetupTestStore(t)
+	ctx := context.Background()
+	for _, tenant := range []string{"tenant-a", "tenant-b", ""} {
+		if err := store.SaveAuditEntry(ctx, &storage.AuditEntry{
+			Timestamp: time.Now(), ActorType: storage.AuditActorUser, ActorID: "someone",
+			Action: "user.update", TenantID: tenant,
+		}); err != nil {
+			t.Fatalf("failed to seed audit entry: %v", err)
+		}
+	}
+
+	for _, target := range []string{"/api/v1/audit/logs", "/api/v1/audit/logs?limit=10"} {
+		req := InjectTestUser(httptest.NewRequest(http.MethodGet, target, nil), NewTestUser(storage.RoleAuditor, "tenant-a"))
+		rec := httptest.NewRecorder()
+		handleAuditLogs(rec, req)
+		var resp struct {
+			Entries    []storage.AuditEntry `json:"entries"`
+			TotalCount *int64               `json:"total_count"`
+		}
+		if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil || rec.Code != http.StatusOK {
+			t.Fatalf("%s: status %d body %s", target, rec.Code, rec.Body.String())
+		}
+		if len(resp.Entries) != 1 || resp.Entries[0].TenantID != "tenant-a" {
+			t.Fatalf("%s: expected only tenant-a entries, got %+v", target, resp.Entries)
+		}
+		if resp.TotalCount != nil && *resp.TotalCount != 1 {
+			t.Fatalf("%s: total_count %d leaks other tenants", target, *resp.TotalCount)
+		}
+	}
+}
+
 func TestHandleLogsAuthorization(t *testing.T) {
 	t.Parallel()
 
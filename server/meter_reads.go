@@ -0,0 +1,395 @@
+package main
+
+import (
+	"context"
+	"crypto/ed25519"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"printmaster/common/meterread"
+	authz "printmaster/server/authz"
+	"printmaster/server/billing"
+	"printmaster/server/storage"
+)
+
+type meterReadRejection struct {
+	ID     string `json:"id"`
+	Reason string `json:"reason"`
+}
+
+// handleMeterReadsBatch receives certified meter reads from an agent. Each
+// read's signature is verified against the signing key it names, which must
+// be one the agent registered; keys are never retired, so reads queued long
+// before a key change still verify. Reads already stored are acknowledged
+// again so the agent stops retrying; reads that fail to store, or that name a
+// key the server has not seen yet, are neither accepted nor rejected and will
+// be re-sent.
+func handleMeterReadsBatch(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "POST only", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req struct {
+		AgentID string           `json:"agent_id"`
+		Reads   []meterread.Read `json:"reads"`
+	}
+	if err := decodeJSONBody(r, &req); err != nil {
+		logWarn("Invalid JSON in meter reads batch", "error", err)
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	agent := r.Context().Value(agentContextKey).(*storage.Agent)
+	logInfo("Meter reads batch received", "agent_id", agent.AgentID, "count", len(req.Reads))
+
+	ctx := r.Context()
+	keys, err := agentSigningKeys(ctx, agent.AgentID)
+	if err != nil {
+		logError("Failed to load agent signing keys", "agent_id", agent.AgentID, "error", err)
+		http.Error(w, "failed to verify meter reads", http.StatusInternalServerError)
+		return
+	}
+	accepted := []string{}
+	rejected := []meterReadRejection{}
+	now := time.Now().UTC()
+	for _, read := range req.Reads {
+		reason := ""
+		switch {
+		case read.ID == "" || read.Serial == "":
+			reason = "missing id or serial"
+		case read.AgentID != agent.AgentID:
+			reason = "read was taken by another agent"
+		case read.Signature == "":
+			reason = meterread.ErrUnsigned.Error()
+		case keys[read.KeyID] == nil:
+			logWarn("Meter read signed with an unregistered key", "agent_id", agent.AgentID, "read_id", read.ID, "key_id", read.KeyID)
+			continue
+		default:
+			if err := read.Verify(keys[read.KeyID]); err != nil {
+				reason = err.Error()
+			}
+		}
+		if reason != "" {
+			logWarn("Rejected meter read", "agent_id", agent.AgentID, "read_id", read.ID, "serial", read.Serial, "reason", reason)
+			rejected = append(rejected, meterReadRejection{ID: read.ID, Reason: reason})
+			continue
+		}
+
+		read.UploadedAt = time.Time{}
+		read.QuarantinedAt, read.QuarantineReason = time.Time{}, ""
+		err := serverStore.AddMeterRead(ctx, &storage.MeterRead{Read: read, TenantID: agent.TenantID, ReceivedAt: now})
+		if err != nil && !errors.Is(err, storage.ErrMeterReadExists) {
+			logError("Failed to store meter read", "read_id", read.ID, "serial", read.Serial, "error", err)
+			continue
+		}
+		accepted = append(accepted, read.ID)
+	}
+
+	logAuditEntry(ctx, &storage.AuditEntry{
+		ActorType: storage.AuditActorAgent,
+		ActorID:   agent.AgentID,
+		ActorName: agent.Name,
+		TenantID:  agent.TenantID,
+		Action:    "upload_meter_reads",
+		Details:   fmt.Sprintf("Uploaded %d meter reads (%d accepted, %d rejected)", len(req.Reads), len(accepted), len(rejected)),
+		Metadata: map[string]interface{}{
+			"received": len(req.Reads),
+			"accepted": len(accepted),
+			"rejected": len(rejected),
+		},
+		IPAddress: extractClientIP(r),
+	})
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"accepted": accepted,
+		"rejected": rejected,
+	})
+}
+
+// agentSigningKeys returns the agent's registered meter read keys by key ID.
+func agentSigningKeys(ctx context.Context, agentID string) (map[string]ed25519.PublicKey, error) {
+	stored, err := serverStore.ListAgentSigningKeys(ctx, agentID)
+	if err != nil {
+		return nil, err
+	}
+	keys := make(map[string]ed25519.PublicKey, len(stored))
+	for _, k := range stored {
+		pub, err := meterread.ParsePublicKey(k.PublicKey)
+		if err != nil {
+			logWarn("Skipping invalid agent signing key", "agent_id", agentID, "key_id", k.KeyID, "error", err)
+			continue
+		}
+		keys[k.KeyID] = pub
+	}
+	return keys, nil
+}
+
+// registerAgentSigningKey records the meter read key an agent announces in
+// its heartbeat. An agent's first key is trusted on first use (agents that
+// joined before keys existed); after that a new key is only accepted through
+// a join token, since a bearer token alone must not let anyone substitute
+// the key reads are verified with.
+func registerAgentSigningKey(ctx context.Context, agent *storage.Agent, encoded, clientIP string) {
+	if encoded == "" {
+		return
+	}
+	pub, err := meterread.ParsePublicKey(encoded)
+	if err != nil {
+		logWarn("Ignoring invalid meter read key in heartbeat", "agent_id", agent.AgentID, "error", err)
+		return
+	}
+	keyID := meterread.KeyID(pub)
+	existing, err := serverStore.ListAgentSigningKeys(ctx, agent.AgentID)
+	if err != nil {
+		logWarn("Failed to load agent signing keys", "agent_id", agent.AgentID, "error", err)
+		return
+	}
+	for _, k := range existing {
+		if k.KeyID == keyID {
+			return
+		}
+	}
+
+	action, details := "agent.signing_key_registered", "Registered meter read signing key "+keyID
+	if len(existing) > 0 {
+		action, details = "agent.signing_key_rejected", "Ignored new meter read signing key "+keyID+"; re-join with a join token to replace the key"
+		logWarn("Agent announced an unregistered meter read key", "agent_id", agent.AgentID, "key_id", keyID)
+	} else if err := serverStore.AddAgentSigningKey(ctx, &storage.AgentSigningKey{AgentID: agent.AgentID, KeyID: keyID, PublicKey: encoded}); err != nil {
+		logWarn("Failed to register agent signing key", "agent_id", agent.AgentID, "error", err)
+		return
+	} else {
+		logInfo("Registered agent meter read key", "agent_id", agent.AgentID, "key_id", keyID)
+	}
+	logAuditEntry(ctx, &storage.AuditEntry{
+		ActorType:  storage.AuditActorAgent,
+		ActorID:    agent.AgentID,
+		ActorName:  agent.Name,
+		TenantID:   agent.TenantID,
+		Action:     action,
+		TargetType: "agent",
+		TargetID:   agent.AgentID,
+		Details:    details,
+		Metadata:   map[string]interface{}{"key_id": keyID},
+		IPAddress:  clientIP,
+	})
+}
+
+// handleMeterReads lists stored certified reads
+// (GET ?serial=&period=&tenant_id=&limit=).
+func handleMeterReads(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "GET only", http.StatusMethodNotAllowed)
+		return
+	}
+	tenantIDs := billingTenantIDs(r)
+	if !authorizeOrReject(w, r, authz.ActionBillingRead, authz.ResourceRef{TenantIDs: tenantIDs}) {
+		return
+	}
+	q := r.URL.Query()
+	limit := 500
+	if v := strings.TrimSpace(q.Get("limit")); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			http.Error(w, "invalid limit", http.StatusBadRequest)
+			return
+		}
+		limit = n
+	}
+
+	reads, err := serverStore.ListMeterReads(r.Context(), storage.MeterReadFilter{
+		Serial:    strings.TrimSpace(q.Get("serial")),
+		Period:    strings.TrimSpace(q.Get("period")),
+		TenantIDs: tenantIDs,
+		Limit:     limit,
+	})
+	if err != nil {
+		logError("Failed to list meter reads", "error", err)
+		http.Error(w, "failed to list meter reads", http.StatusInternalServerError)
+		return
+	}
+	if reads == nil {
+		reads = []*storage.MeterRead{}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(reads)
+}
+
+// handleMeterReadsReconcile returns a period's reconciled reads grouped by
+// contract (GET ?period=YYYY-MM&tenant_id=&format=json|csv). Newly detected
+// anomalies are recorded for review as a side effect.
+func handleMeterReadsReconcile(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "GET only", http.StatusMethodNotAllowed)
+		return
+	}
+	tenantIDs := billingTenantIDs(r)
+	if !authorizeOrReject(w, r, authz.ActionBillingRead, authz.ResourceRef{TenantIDs: tenantIDs}) {
+		return
+	}
+	period, err := billingPeriodParam(r.URL.Query().Get("period"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	contracts, err := billing.NewReconciler(serverStore).Reconcile(r.Context(), period, tenantIDs)
+	if err != nil {
+		logError("Failed to reconcile meter reads", "period", period.String(), "error", err)
+		http.Error(w, "failed to reconcile meter reads", http.StatusInternalServerError)
+		return
+	}
+
+	switch strings.ToLower(strings.TrimSpace(r.URL.Query().Get("format"))) {
+	case "", "json":
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"period":       period.String(),
+			"generated_at": time.Now().UTC(),
+			"contracts":    contracts,
+		})
+	case "csv":
+		w.Header().Set("Content-Type", "text/csv")
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"meter-reads-%s.csv\"", period.String()))
+		cw := csv.NewWriter(w)
+		cw.Write(billing.ReconcileCSVHeader)
+		for _, c := range contracts {
+			for _, rr := range c.Reads {
+				cw.Write(billing.ReconcileCSVRecord(c.Contract, rr))
+			}
+		}
+		cw.Flush()
+	default:
+		http.Error(w, "format must be json or csv", http.StatusBadRequest)
+	}
+}
+
+// handleMeterReadAnomalies lists reconciliation anomalies
+// (GET ?period=&status=&tenant_id=).
+func handleMeterReadAnomalies(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "GET only", http.StatusMethodNotAllowed)
+		return
+	}
+	tenantIDs := billingTenantIDs(r)
+	if !authorizeOrReject(w, r, authz.ActionBillingRead, authz.ResourceRef{TenantIDs: tenantIDs}) {
+		return
+	}
+	q := r.URL.Query()
+	anomalies, err := serverStore.ListMeterReadAnomalies(r.Context(), storage.MeterReadAnomalyFilter{
+		TenantIDs: tenantIDs,
+		Period:    strings.TrimSpace(q.Get("period")),
+		Status:    strings.TrimSpace(q.Get("status")),
+	})
+	if err != nil {
+		logError("Failed to list meter read anomalies", "error", err)
+		http.Error(w, "failed to list anomalies", http.StatusInternalServerError)
+		return
+	}
+	if anomalies == nil {
+		anomalies = []*storage.MeterReadAnomaly{}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(anomalies)
+}
+
+// handleMeterReadAnomalyResolve records a reviewer's decision on an anomaly:
+// "accept" keeps the computed delta, "adjust" replaces it with the given
+// mono/color page counts.
+func handleMeterReadAnomalyResolve(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "POST only", http.StatusMethodNotAllowed)
+		return
+	}
+	var req struct {
+		ID         int64  `json:"id"`
+		Resolution string `json:"resolution"`
+		MonoPages  int64  `json:"mono_pages"`
+		ColorPages int64  `json:"color_pages"`
+		Note       string `json:"note"`
+	}
+	if err := decodeJSONBody(r, &req); err != nil {
+		http.Error(w, "invalid JSON", http.StatusBadRequest)
+		return
+	}
+	if req.ID <= 0 {
+		http.Error(w, "id is required", http.StatusBadRequest)
+		return
+	}
+
+	anomalies, err := serverStore.ListMeterReadAnomalies(r.Context(), storage.MeterReadAnomalyFilter{ID: req.ID})
+	if err != nil {
+		logError("Failed to load meter read anomaly", "id", req.ID, "error", err)
+		http.Error(w, "failed to load anomaly", http.StatusInternalServerError)
+		return
+	}
+	if len(anomalies) == 0 {
+		http.Error(w, "anomaly not found", http.StatusNotFound)
+		return
+	}
+	anomaly := anomalies[0]
+	var tenantIDs []string
+	if anomaly.TenantID != "" {
+		tenantIDs = []string{anomaly.TenantID}
+	}
+	if !authorizeOrReject(w, r, authz.ActionBillingWrite, authz.ResourceRef{TenantIDs: tenantIDs}) {
+		return
+	}
+
+	switch strings.ToLower(strings.TrimSpace(req.Resolution)) {
+	case "accept":
+		anomaly.Status = storage.MeterAnomalyAccepted
+	case "adjust":
+		if req.MonoPages < 0 || req.ColorPages < 0 {
+			http.Error(w, "page counts must not be negative", http.StatusBadRequest)
+			return
+		}
+		anomaly.Status = storage.MeterAnomalyAdjusted
+		anomaly.AdjustedMono = req.MonoPages
+		anomaly.AdjustedColor = req.ColorPages
+	default:
+		http.Error(w, "resolution must be accept or adjust", http.StatusBadRequest)
+		return
+	}
+	actor := ""
+	if principal := getPrincipal(r); principal != nil && principal.User != nil {
+		actor = principal.User.Username
+	}
+	anomaly.Note = strings.TrimSpace(req.Note)
+	anomaly.ResolvedBy = actor
+	anomaly.ResolvedAt = time.Now().UTC()
+	if err := serverStore.ResolveMeterReadAnomaly(r.Context(), anomaly); err != nil {
+		if errors.Is(err, storage.ErrMeterAnomalyNotFound) {
+			http.Error(w, "anomaly not found", http.StatusNotFound)
+			return
+		}
+		logError("Failed to resolve meter read anomaly", "id", anomaly.ID, "error", err)
+		http.Error(w, "failed to resolve anomaly", http.StatusInternalServerError)
+		return
+	}
+
+	logInfo("Meter read anomaly resolved", "id", anomaly.ID, "serial", anomaly.Serial, "status", anomaly.Status, "resolved_by", actor)
+	logAuditEntry(r.Context(), &storage.AuditEntry{
+		ActorType:  storage.AuditActorUser,
+		ActorID:    actor,
+		ActorName:  actor,
+		TenantID:   anomaly.TenantID,
+		Action:     "billing.meter_read.resolve",
+		TargetType: "meter_read",
+		TargetID:   anomaly.ReadID,
+		Details: fmt.Sprintf("Resolved %s anomaly on %s (%s) as %s",
+			anomaly.Kind, anomaly.Serial, anomaly.Period, anomaly.Status),
+		IPAddress: extractClientIP(r),
+		UserAgent: r.Header.Get("User-Agent"),
+	})
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(anomaly)
+}
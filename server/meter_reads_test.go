@@ -0,0 +1,224 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"printmaster/common/meterread"
+	"printmaster/server/billing"
+	"printmaster/server/storage"
+)
+
+func TestHandleMeterReadsBatchVerifiesSignatures(t *testing.T) {
+	store := SetupTestStore(t)
+	ctx := context.Background()
+	agent := &storage.Agent{
+		AgentID:      "agent-mr",
+		Hostname:     "host",
+		Token:        "agent-token",
+		TenantID:     "tenant-a",
+		RegisteredAt: time.Now(),
+		LastSeen:     time.Now(),
+		Status:       "active",
+	}
+	if err := store.RegisterAgent(ctx, agent); err != nil {
+		t.Fatalf("RegisterAgent: %v", err)
+	}
+	pub, key := newTestSigningKey(t)
+	registerTestSigningKey(t, store, agent.AgentID, pub)
+	_, otherKey := newTestSigningKey(t)
+
+	newRead := func(id, period string, pages int64) meterread.Read {
+		p, _ := billing.ParsePeriod(period)
+		return meterread.Read{
+			ID:        id,
+			Serial:    "SN1",
+			AgentID:   agent.AgentID,
+			Period:    period,
+			Trigger:   meterread.TriggerScheduled,
+			ReadAt:    p.Start.Add(time.Hour),
+			PageCount: pages,
+			MonoPages: pages,
+		}
+	}
+	aug := newRead("mr-aug", "2026-08", 1000)
+	aug.Sign(key)
+	sep := newRead("mr-sep", "2026-09", 1600)
+	sep.Sign(key)
+	// Signed by someone else but claiming the agent's key
+	forged := newRead("mr-forged", "2026-09", 1)
+	forged.Sign(otherKey)
+	forged.KeyID = meterread.KeyID(pub)
+	// Signed with a key the server has never seen: kept for a later retry
+	unknown := newRead("mr-unknown", "2026-09", 2)
+	unknown.Sign(otherKey)
+
+	body, _ := json.Marshal(map[string]interface{}{"agent_id": agent.AgentID, "reads": []meterread.Read{aug, sep, forged, unknown}})
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/meter-reads/batch", bytes.NewReader(body))
+	req = req.WithContext(context.WithValue(req.Context(), agentContextKey, agent))
+	rr := httptest.NewRecorder()
+	handleMeterReadsBatch(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	var resp struct {
+		Accepted []string             `json:"accepted"`
+		Rejected []meterReadRejection `json:"rejected"`
+	}
+	if err := json.NewDecoder(rr.Body).Decode(&resp); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if len(resp.Accepted) != 2 || len(resp.Rejected) != 1 || resp.Rejected[0].ID != "mr-forged" {
+		t.Fatalf("unexpected response: %+v", resp)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/api/v1/meter-reads/reconcile?period=2026-09", nil)
+	req = InjectTestAdmin(req)
+	rr = httptest.NewRecorder()
+	handleMeterReadsReconcile(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	var recon struct {
+		Contracts []*billing.ContractReconciliation `json:"contracts"`
+	}
+	if err := json.NewDecoder(rr.Body).Decode(&recon); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if len(recon.Contracts) != 1 || recon.Contracts[0].PagesTotal != 600 || recon.Contracts[0].Reads[0].PreviousReadID != "mr-aug" {
+		t.Fatalf("unexpected reconciliation: %+v", recon.Contracts)
+	}
+}
+
+func TestHandleMeterReadsBatchKeepsOldSigningKeys(t *testing.T) {
+	store := SetupTestStore(t)
+	ctx := context.Background()
+	agent := &storage.Agent{
+		AgentID:      "agent-rot",
+		Hostname:     "host",
+		Token:        "token-before",
+		RegisteredAt: time.Now(),
+		LastSeen:     time.Now(),
+		Status:       "active",
+	}
+	if err := store.RegisterAgent(ctx, agent); err != nil {
+		t.Fatalf("RegisterAgent: %v", err)
+	}
+	oldPub, oldKey := newTestSigningKey(t)
+	registerTestSigningKey(t, store, agent.AgentID, oldPub)
+
+	// Queued with the old key, then the token was rotated (grace long over)
+	// and the agent re-joined with a new key
+	read := meterread.Read{ID: "mr-1", Serial: "SN1", AgentID: agent.AgentID, Period: "2026-09",
+		Trigger: meterread.TriggerScheduled, ReadAt: time.Date(2026, 9, 1, 2, 0, 0, 0, time.UTC), PageCount: 10}
+	read.Sign(oldKey)
+	if err := store.RotateAgentToken(ctx, agent.AgentID, "token-after", "", time.Time{}, time.Time{}); err != nil {
+		t.Fatalf("RotateAgentToken: %v", err)
+	}
+	newPub, _ := newTestSigningKey(t)
+	registerTestSigningKey(t, store, agent.AgentID, newPub)
+
+	authed, err := store.GetAgentByToken(ctx, "token-after")
+	if err != nil {
+		t.Fatalf("GetAgentByToken: %v", err)
+	}
+	body, _ := json.Marshal(map[string]interface{}{"agent_id": agent.AgentID, "reads": []meterread.Read{read}})
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/meter-reads/batch", bytes.NewReader(body))
+	req = req.WithContext(context.WithValue(req.Context(), agentContextKey, authed))
+	rr := httptest.NewRecorder()
+	handleMeterReadsBatch(rr, req)
+	var resp struct {
+		Accepted []string             `json:"accepted"`
+		Rejected []meterReadRejection `json:"rejected"`
+	}
+	if err := json.NewDecoder(rr.Body).Decode(&resp); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if len(resp.Accepted) != 1 || len(resp.Rejected) != 0 {
+		t.Fatalf("read signed with the old key not accepted: %+v", resp)
+	}
+}
+
+func TestRegisterAgentSigningKeyTrustsFirstKeyOnly(t *testing.T) {
+	store := SetupTestStore(t)
+	ctx := context.Background()
+	agent := &storage.Agent{AgentID: "agent-key", Hostname: "host", Token: "tok",
+		RegisteredAt: time.Now(), LastSeen: time.Now(), Status: "active"}
+	if err := store.RegisterAgent(ctx, agent); err != nil {
+		t.Fatalf("RegisterAgent: %v", err)
+	}
+	first, _ := newTestSigningKey(t)
+	second, _ := newTestSigningKey(t)
+
+	registerAgentSigningKey(ctx, agent, meterread.EncodePublicKey(first), "")
+	registerAgentSigningKey(ctx, agent, meterread.EncodePublicKey(first), "")
+	// A bearer token alone must not be enough to substitute the key
+	registerAgentSigningKey(ctx, agent, meterread.EncodePublicKey(second), "")
+
+	keys, err := store.ListAgentSigningKeys(ctx, agent.AgentID)
+	if err != nil {
+		t.Fatalf("ListAgentSigningKeys: %v", err)
+	}
+	if len(keys) != 1 || keys[0].KeyID != meterread.KeyID(first) {
+		t.Fatalf("expected only the first key, got %+v", keys)
+	}
+}
+
+func newTestSigningKey(t *testing.T) (ed25519.PublicKey, ed25519.PrivateKey) {
+	t.Helper()
+	pub, key, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pub, key
+}
+
+func registerTestSigningKey(t *testing.T, store storage.Store, agentID string, pub ed25519.PublicKey) {
+	t.Helper()
+	if err := store.AddAgentSigningKey(context.Background(), &storage.AgentSigningKey{
+		AgentID: agentID, KeyID: meterread.KeyID(pub), PublicKey: meterread.EncodePublicKey(pub),
+	}); err != nil {
+		t.Fatalf("AddAgentSigningKey: %v", err)
+	}
+}
+
+func TestHandleMeterReadAnomalyResolve(t *testing.T) {
+	store := SetupTestStore(t)
+	ctx := context.Background()
+	anomaly := &storage.MeterReadAnomaly{ReadID: "mr-1", Serial: "SN1", TenantID: "tenant-a", Period: "2026-09", Kind: storage.MeterAnomalyCounterReset}
+	if err := store.RecordMeterReadAnomaly(ctx, anomaly); err != nil {
+		t.Fatalf("RecordMeterReadAnomaly: %v", err)
+	}
+	stored, _ := store.ListMeterReadAnomalies(ctx, storage.MeterReadAnomalyFilter{})
+	if len(stored) != 1 {
+		t.Fatalf("expected 1 anomaly, got %d", len(stored))
+	}
+
+	body, _ := json.Marshal(map[string]interface{}{"id": stored[0].ID, "resolution": "adjust", "mono_pages": 120, "note": "board swap"})
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/meter-reads/anomalies/resolve", bytes.NewReader(body))
+	req = InjectTestUser(req, NewTestUser(storage.RoleOperator, "tenant-a"))
+	rr := httptest.NewRecorder()
+	handleMeterReadAnomalyResolve(rr, req)
+	if rr.Code != http.StatusForbidden {
+		t.Fatalf("operator: expected 403, got %d", rr.Code)
+	}
+
+	req = httptest.NewRequest(http.MethodPost, "/api/v1/meter-reads/anomalies/resolve", bytes.NewReader(body))
+	req = InjectTestAdmin(req)
+	rr = httptest.NewRecorder()
+	handleMeterReadAnomalyResolve(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	got, _ := store.ListMeterReadAnomalies(ctx, storage.MeterReadAnomalyFilter{ID: stored[0].ID})
+	if len(got) != 1 || got[0].Status != storage.MeterAnomalyAdjusted || got[0].AdjustedMono != 120 {
+		t.Fatalf("unexpected anomaly after resolve: %+v", got)
+	}
+}
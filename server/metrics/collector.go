@@ -10,9 +10,14 @@ import (
 	"sync"
 	"time"
 
+	"printmaster/server/devicestatus"
 	"printmaster/server/storage"
 )
 
+// OfflineAfter is how long since last seen before an agent or device counts as
+// offline. Other fleet summaries reuse it so their counts agree with the collector.
+const OfflineAfter = 15 * time.Minute
+
 // CollectorConfig configures the metrics collector.
 type CollectorConfig struct {
 	// Interval between raw metric collections (default 10s)
@@ -74,6 +79,7 @@ type Collector struct {
 
 	// Cached latest snapshot for quick access
 	latestSnapshot *storage.ServerMetricsSnapshot
+	lastRun        time.Time // Start of the latest collection
 }
 
 // NewCollector creates a new metrics collector.
@@ -158,6 +164,14 @@ func (c *Collector) GetLatest() *storage.ServerMetricsSnapshot {
 	return c.latestSnapshot
 }
 
+// LastRun returns when the latest collection started, or the zero time if
+// none has run yet.
+func (c *Collector) LastRun() time.Time {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.lastRun
+}
+
 func (c *Collector) runLoop() {
 	collectionTicker := time.NewTicker(c.config.CollectionInterval)
 	aggregationTicker := time.NewTicker(c.config.AggregationInterval)
@@ -185,6 +199,10 @@ func (c *Collector) runLoop() {
 }
 
 func (c *Collector) collect() {
+	c.mu.Lock()
+	c.lastRun = time.Now()
+	c.mu.Unlock()
+
 	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 	defer cancel()
 
@@ -239,7 +257,7 @@ func (c *Collector) collectFleetMetrics(ctx context.Context, fleet *storage.Flee
 		now := time.Now()
 		for _, a := range agents {
 			// Check agent status
-			isActive := a.Status == "active" && now.Sub(a.LastSeen) < 15*time.Minute
+			isActive := a.Status == "active" && now.Sub(a.LastSeen) < OfflineAfter
 
 			if !isActive {
 				fleet.AgentsOffline++
@@ -259,14 +277,14 @@ func (c *Collector) collectFleetMetrics(ctx context.Context, fleet *storage.Flee
 		now := time.Now()
 		for _, d := range devices {
 			// Online/offline based on last seen
-			if now.Sub(d.LastSeen) < 15*time.Minute {
+			if now.Sub(d.LastSeen) < OfflineAfter {
 				fleet.DevicesOnline++
 			} else {
 				fleet.DevicesOffline++
 			}
 
 			// Status classification
-			hasError, hasWarning, hasJam := classifyStatusMessages(d.StatusMessages)
+			hasError, hasWarning, hasJam := classifyStatusMessages(d.Manufacturer, d.StatusMessages)
 			if hasJam {
 				fleet.DevicesJam++
 				fleet.DevicesError++
@@ -395,20 +413,10 @@ func (c *Collector) prune() {
 
 // Helper functions
 
-func classifyStatusMessages(messages []string) (hasError, hasWarning, hasJam bool) {
-	for _, msg := range messages {
-		msgLower := stringToLower(msg)
-		if containsAny(msgLower, "jam", "jammed") {
-			hasJam = true
-		}
-		if containsAny(msgLower, "error", "fault", "failure") {
-			hasError = true
-		}
-		if containsAny(msgLower, "warning", "low", "empty") {
-			hasWarning = true
-		}
-	}
-	return
+// classifyStatusMessages maps raw status messages to fleet counter flags using
+// the shared device status rules.
+func classifyStatusMessages(vendor string, messages []string) (hasError, hasWarning, hasJam bool) {
+	return devicestatus.Active().Flags(vendor, messages)
 }
 
 func tonerLevelToInt(v interface{}) int {
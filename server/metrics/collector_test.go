@@ -620,8 +620,8 @@ func TestClassifyStatusMessages(t *testing.T) {
 		{"warning", []string{"Toner warning"}, false, true, false},
 		{"low", []string{"Paper low"}, false, true, false},
 		{"empty supply", []string{"Toner empty"}, false, true, false},
-		{"jam", []string{"Paper jam"}, false, false, true},
-		{"jammed", []string{"Printer jammed"}, false, false, true},
+		{"jam", []string{"Paper jam"}, true, false, true},
+		{"jammed", []string{"Printer jammed"}, true, false, true},
 		{"multiple", []string{"Jam", "Error", "Low"}, true, true, true},
 		{"case insensitive", []string{"ERROR"}, true, false, false},
 	}
@@ -630,7 +630,7 @@ func TestClassifyStatusMessages(t *testing.T) {
 		t.Run(tt.name, func(t *testing.T) {
 			t.Parallel()
 
-			hasError, hasWarning, hasJam := classifyStatusMessages(tt.messages)
+			hasError, hasWarning, hasJam := classifyStatusMessages("", tt.messages)
 			if hasError != tt.wantError {
 				t.Errorf("hasError = %v, want %v", hasError, tt.wantError)
 			}
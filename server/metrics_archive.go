@@ -0,0 +1,104 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	authz "printmaster/server/authz"
+	"printmaster/server/metricsarchive"
+	"printmaster/server/storage"
+)
+
+// metricsArchiver moves old metrics into Parquet cold storage; nil when the
+// archive is disabled.
+var metricsArchiver *metricsarchive.Archiver
+
+// newMetricsArchiver builds the archiver from config. Archive files default
+// to <dataDir>/archive/metrics.
+func newMetricsArchiver(cfg MetricsArchiveConfig, store storage.Store, dataDir string) *metricsarchive.Archiver {
+	dir := cfg.Dir
+	if dir == "" {
+		dir = filepath.Join(dataDir, "archive", "metrics")
+	}
+	years := cfg.MinAgeYears
+	if years <= 0 {
+		years = 3
+	}
+	return metricsarchive.New(store, metricsarchive.Config{
+		Dir:        dir,
+		MinAge:     time.Duration(years) * 365 * 24 * time.Hour,
+		RestoreFor: time.Duration(cfg.RestoreDays) * 24 * time.Hour,
+	})
+}
+
+// handleMetricsArchives lists archived metrics periods.
+// GET /api/v1/metrics/archives
+func handleMetricsArchives(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "GET only", http.StatusMethodNotAllowed)
+		return
+	}
+	if !authorizeOrReject(w, r, authz.ActionSettingsServerRead, authz.ResourceRef{}) {
+		return
+	}
+	archives, err := serverStore.ListMetricsArchives(r.Context())
+	if err != nil {
+		logError("Metrics archive: failed to list archives", "error", err)
+		http.Error(w, "failed to list archives", http.StatusInternalServerError)
+		return
+	}
+	if archives == nil {
+		archives = []*storage.MetricsArchive{}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"enabled":  metricsArchiver != nil,
+		"archives": archives,
+	})
+}
+
+// handleMetricsArchiveRoute restores an archived period to full resolution.
+// POST /api/v1/metrics/archives/{id}/restore
+func handleMetricsArchiveRoute(w http.ResponseWriter, r *http.Request) {
+	rest := strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/v1/metrics/archives/"), "/")
+	idStr, action, _ := strings.Cut(rest, "/")
+	id, err := strconv.ParseInt(idStr, 10, 64)
+	if err != nil || id <= 0 || action != "restore" {
+		http.NotFound(w, r)
+		return
+	}
+	if r.Method != http.MethodPost {
+		http.Error(w, "POST only", http.StatusMethodNotAllowed)
+		return
+	}
+	if !authorizeOrReject(w, r, authz.ActionSettingsServerWrite, authz.ResourceRef{}) {
+		return
+	}
+	if metricsArchiver == nil {
+		http.Error(w, "metrics archive is disabled", http.StatusServiceUnavailable)
+		return
+	}
+
+	rec, inserted, skipped, err := metricsArchiver.Restore(r.Context(), id)
+	if errors.Is(err, metricsarchive.ErrNotFound) {
+		http.Error(w, "archive not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		logError("Metrics archive: restore failed", "id", id, "error", err)
+		http.Error(w, "failed to restore archive", http.StatusInternalServerError)
+		return
+	}
+	logInfo("Restored archived metrics", "period", rec.PeriodStart.Format("2006-01"), "inserted", inserted, "skipped", skipped, "until", rec.RestoredUntil)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"archive":  rec,
+		"inserted": inserted,
+		"skipped":  skipped,
+	})
+}
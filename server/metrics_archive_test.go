@@ -0,0 +1,72 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"printmaster/server/storage"
+)
+
+func TestMetricsArchiveHandlers(t *testing.T) {
+	store := SetupTestStore(t)
+	ctx := context.Background()
+
+	rec := &storage.MetricsArchive{
+		PeriodStart: time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC),
+		PeriodEnd:   time.Date(2020, 2, 1, 0, 0, 0, 0, time.UTC),
+		Path:        "/nonexistent/metrics-2020-01.parquet",
+		RowCount:    10,
+	}
+	if err := store.CreateMetricsArchive(ctx, rec); err != nil {
+		t.Fatalf("CreateMetricsArchive: %v", err)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/metrics/archives", nil)
+	rr := httptest.NewRecorder()
+	handleMetricsArchives(rr, InjectTestUser(req, NewTestAdminUser()))
+	var resp struct {
+		Enabled  bool                      `json:"enabled"`
+		Archives []*storage.MetricsArchive `json:"archives"`
+	}
+	if rr.Code != http.StatusOK {
+		t.Fatalf("list: code=%d body=%s", rr.Code, rr.Body.String())
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil || len(resp.Archives) != 1 || resp.Enabled {
+		t.Fatalf("list response = %+v, %v", resp, err)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/api/v1/metrics/archives", nil)
+	rr = httptest.NewRecorder()
+	handleMetricsArchives(rr, InjectTestUser(req, NewTestUser(storage.RoleViewer, "tenant-a")))
+	if rr.Code != http.StatusForbidden {
+		t.Errorf("viewer list: code=%d, want 403", rr.Code)
+	}
+
+	restore := func(path string) int {
+		req := httptest.NewRequest(http.MethodPost, path, nil)
+		rr := httptest.NewRecorder()
+		handleMetricsArchiveRoute(rr, InjectTestUser(req, NewTestAdminUser()))
+		return rr.Code
+	}
+	if code := restore("/api/v1/metrics/archives/1/restore"); code != http.StatusServiceUnavailable {
+		t.Errorf("restore while disabled: code=%d, want 503", code)
+	}
+
+	metricsArchiver = newMetricsArchiver(MetricsArchiveConfig{Dir: t.TempDir()}, store, "")
+	defer func() { metricsArchiver = nil }()
+	if code := restore("/api/v1/metrics/archives/999/restore"); code != http.StatusNotFound {
+		t.Errorf("restore unknown: code=%d, want 404", code)
+	}
+	if code := restore("/api/v1/metrics/archives/abc/restore"); code != http.StatusNotFound {
+		t.Errorf("restore bad id: code=%d, want 404", code)
+	}
+	// The archive file is missing, so the restore fails rather than
+	// pretending to succeed
+	if code := restore("/api/v1/metrics/archives/1/restore"); code != http.StatusInternalServerError {
+		t.Errorf("restore missing file: code=%d, want 500", code)
+	}
+}
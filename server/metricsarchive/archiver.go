@@ -0,0 +1,346 @@
+// Package metricsarchive moves old device metrics into cold storage. Each
+// calendar month older than the configured age is written to a compressed
+// Parquet file; metrics_history keeps only the last snapshot per device and
+// day for that month, so charts, reports and billing still see the period.
+// An archived month can be restored to full resolution on demand; restored
+// rows are thinned again once the restore window ends.
+package metricsarchive
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"printmaster/server/storage"
+)
+
+// Store is the subset of storage.Store used by the archiver.
+type Store interface {
+	GetOldestMetricsTimestamp(ctx context.Context) (time.Time, error)
+	ListMetricsInRange(ctx context.Context, start, end time.Time) ([]*storage.MetricsSnapshot, error)
+	ReplaceMetricsInRange(ctx context.Context, start, end time.Time, keep []*storage.MetricsSnapshot) (int64, error)
+	InsertMissingMetrics(ctx context.Context, metrics []*storage.MetricsSnapshot) (inserted, skipped int, err error)
+	CreateMetricsArchive(ctx context.Context, a *storage.MetricsArchive) error
+	UpdateMetricsArchive(ctx context.Context, a *storage.MetricsArchive) error
+	GetMetricsArchive(ctx context.Context, id int64) (*storage.MetricsArchive, error)
+	ListMetricsArchives(ctx context.Context) ([]*storage.MetricsArchive, error)
+	DeleteMetricsArchive(ctx context.Context, id int64) error
+}
+
+// ErrNotFound is returned by Restore for an unknown archive.
+var ErrNotFound = errors.New("archive not found")
+
+// Config controls what is archived and where.
+type Config struct {
+	Dir        string        // Directory for archive files
+	MinAge     time.Duration // Months ending longer ago than this are archived
+	RestoreFor time.Duration // How long restored rows are kept at full resolution
+}
+
+// Archiver archives and restores metrics periods. Methods are safe for
+// concurrent use; runs are serialized.
+type Archiver struct {
+	store Store
+	cfg   Config
+	now   func() time.Time
+	mu    sync.Mutex
+}
+
+// New creates an archiver. Dir is created on first use.
+func New(store Store, cfg Config) *Archiver {
+	if cfg.RestoreFor <= 0 {
+		cfg.RestoreFor = 7 * 24 * time.Hour
+	}
+	return &Archiver{store: store, cfg: cfg, now: func() time.Time { return time.Now().UTC() }}
+}
+
+// Run archives due periods and expires restores every interval until ctx
+// ends. The first pass runs immediately.
+func (a *Archiver) Run(ctx context.Context, interval time.Duration, logf func(msg string, args ...interface{})) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		archived, err := a.ArchiveDue(ctx)
+		for _, rec := range archived {
+			logf("Archived metrics period", "period", rec.PeriodStart.Format("2006-01"), "rows", rec.RowCount, "summary_rows", rec.SummaryRows, "path", rec.Path)
+		}
+		if err != nil && ctx.Err() == nil {
+			logf("Metrics archive run failed", "error", err)
+		}
+		if expired, err := a.ExpireRestores(ctx); err != nil && ctx.Err() == nil {
+			logf("Expiring restored metrics failed", "error", err)
+		} else if expired > 0 {
+			logf("Thinned restored metrics periods", "count", expired)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// cutoff is the end of the newest month old enough to archive.
+func (a *Archiver) cutoff() time.Time {
+	t := a.now().Add(-a.cfg.MinAge)
+	return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+}
+
+// ArchiveDue archives every month older than MinAge that has no archive yet
+// and returns the new archive records.
+func (a *Archiver) ArchiveDue(ctx context.Context) ([]*storage.MetricsArchive, error) {
+	if a.cfg.MinAge <= 0 {
+		return nil, errors.New("metrics archive: min age not set")
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	oldest, err := a.store.GetOldestMetricsTimestamp(ctx)
+	if err != nil || oldest.IsZero() {
+		return nil, err
+	}
+	existing, err := a.store.ListMetricsArchives(ctx)
+	if err != nil {
+		return nil, err
+	}
+	done := make(map[time.Time]bool, len(existing))
+	for _, rec := range existing {
+		done[rec.PeriodStart.UTC()] = true
+	}
+
+	var created []*storage.MetricsArchive
+	cutoff := a.cutoff()
+	oldest = oldest.UTC()
+	for start := time.Date(oldest.Year(), oldest.Month(), 1, 0, 0, 0, 0, time.UTC); start.Before(cutoff); start = start.AddDate(0, 1, 0) {
+		if err := ctx.Err(); err != nil {
+			return created, err
+		}
+		if done[start] {
+			continue
+		}
+		rec, err := a.archivePeriod(ctx, start, start.AddDate(0, 1, 0))
+		if err != nil {
+			return created, fmt.Errorf("archive %s: %w", start.Format("2006-01"), err)
+		}
+		if rec != nil {
+			created = append(created, rec)
+		}
+	}
+	return created, nil
+}
+
+// archivePeriod writes one month to a Parquet file, records it and thins the
+// month in the database. Returns nil when the month has no metrics.
+func (a *Archiver) archivePeriod(ctx context.Context, start, end time.Time) (*storage.MetricsArchive, error) {
+	metrics, err := a.store.ListMetricsInRange(ctx, start, end)
+	if err != nil || len(metrics) == 0 {
+		return nil, err
+	}
+
+	devices := map[string]bool{}
+	for _, m := range metrics {
+		devices[m.Serial] = true
+	}
+
+	if err := os.MkdirAll(a.cfg.Dir, 0o755); err != nil {
+		return nil, err
+	}
+	name := "metrics-" + start.Format("2006-01") + ".parquet"
+	path := filepath.Join(a.cfg.Dir, name)
+	size, sum, err := writeArchiveFile(path, metrics, map[string]string{
+		"printmaster.period_start": start.Format(time.RFC3339),
+		"printmaster.period_end":   end.Format(time.RFC3339),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	rec := &storage.MetricsArchive{
+		PeriodStart: start,
+		PeriodEnd:   end,
+		Path:        path,
+		RowCount:    int64(len(metrics)),
+		DeviceCount: len(devices),
+		SizeBytes:   size,
+		SHA256:      sum,
+	}
+	// Record the file before thinning: if thinning fails the full rows are
+	// still in the database and the record is dropped so the next run retries
+	if err := a.store.CreateMetricsArchive(ctx, rec); err != nil {
+		os.Remove(path)
+		return nil, err
+	}
+	kept, err := a.thin(ctx, rec, metrics)
+	if err != nil {
+		if derr := a.store.DeleteMetricsArchive(ctx, rec.ID); derr != nil {
+			return nil, fmt.Errorf("%w (and removing the archive record failed: %v)", err, derr)
+		}
+		os.Remove(path)
+		return nil, err
+	}
+	rec.SummaryRows = kept
+	return rec, a.store.UpdateMetricsArchive(ctx, rec)
+}
+
+// thin replaces the period's rows with one summary row per device and day.
+// Only rows present in the archive are summarized; anything added to the
+// period since (imports, late uploads) is kept as is.
+func (a *Archiver) thin(ctx context.Context, rec *storage.MetricsArchive, archived []*storage.MetricsSnapshot) (int64, error) {
+	inArchive := make(map[string]bool, len(archived))
+	for _, m := range archived {
+		inArchive[storage.MetricsRowKey(m.Serial, m.Timestamp)] = true
+	}
+	current, err := a.store.ListMetricsInRange(ctx, rec.PeriodStart, rec.PeriodEnd)
+	if err != nil {
+		return 0, err
+	}
+
+	var keep []*storage.MetricsSnapshot
+	lastOfDay := map[string]*storage.MetricsSnapshot{}
+	var order []string
+	for _, m := range current {
+		if !inArchive[storage.MetricsRowKey(m.Serial, m.Timestamp)] {
+			keep = append(keep, m)
+			continue
+		}
+		day := m.Serial + "|" + m.Timestamp.UTC().Format("2006-01-02")
+		prev, ok := lastOfDay[day]
+		if !ok {
+			order = append(order, day)
+		}
+		if !ok || !m.Timestamp.Before(prev.Timestamp) {
+			lastOfDay[day] = m
+		}
+	}
+	for _, day := range order {
+		keep = append(keep, lastOfDay[day])
+	}
+	if _, err := a.store.ReplaceMetricsInRange(ctx, rec.PeriodStart, rec.PeriodEnd, keep); err != nil {
+		return 0, err
+	}
+	return int64(len(keep)), nil
+}
+
+// Restore copies an archived period back into metrics_history at full
+// resolution until RestoreFor has passed. Rows already present are left
+// alone; rows for deleted devices or agents are skipped.
+func (a *Archiver) Restore(ctx context.Context, id int64) (*storage.MetricsArchive, int, int, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	rec, err := a.store.GetMetricsArchive(ctx, id)
+	if err != nil {
+		return nil, 0, 0, err
+	}
+	if rec == nil {
+		return nil, 0, 0, ErrNotFound
+	}
+	metrics, err := readArchiveFile(rec)
+	if err != nil {
+		return nil, 0, 0, err
+	}
+	inserted, skipped, err := a.store.InsertMissingMetrics(ctx, metrics)
+	if err != nil {
+		return nil, 0, 0, err
+	}
+	now := a.now()
+	until := now.Add(a.cfg.RestoreFor)
+	rec.RestoredAt, rec.RestoredUntil = &now, &until
+	if err := a.store.UpdateMetricsArchive(ctx, rec); err != nil {
+		return nil, 0, 0, err
+	}
+	return rec, inserted, skipped, nil
+}
+
+// ExpireRestores thins periods whose restore window has ended and returns
+// how many were thinned.
+func (a *Archiver) ExpireRestores(ctx context.Context) (int, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	archives, err := a.store.ListMetricsArchives(ctx)
+	if err != nil {
+		return 0, err
+	}
+	now := a.now()
+	expired := 0
+	for _, rec := range archives {
+		if rec.RestoredUntil == nil || rec.RestoredUntil.After(now) {
+			continue
+		}
+		metrics, err := readArchiveFile(rec)
+		if err != nil {
+			return expired, fmt.Errorf("archive %d: %w", rec.ID, err)
+		}
+		kept, err := a.thin(ctx, rec, metrics)
+		if err != nil {
+			return expired, fmt.Errorf("archive %d: %w", rec.ID, err)
+		}
+		rec.SummaryRows, rec.RestoredAt, rec.RestoredUntil = kept, nil, nil
+		if err := a.store.UpdateMetricsArchive(ctx, rec); err != nil {
+			return expired, err
+		}
+		expired++
+	}
+	return expired, nil
+}
+
+// writeArchiveFile writes rows to path through a temporary file and returns
+// the file size and SHA-256.
+func writeArchiveFile(path string, metrics []*storage.MetricsSnapshot, meta map[string]string) (int64, string, error) {
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".metrics-*.parquet.tmp")
+	if err != nil {
+		return 0, "", err
+	}
+	defer os.Remove(tmp.Name())
+
+	h := sha256.New()
+	cw := &countingWriter{w: io.MultiWriter(tmp, h)}
+	if err := writeMetrics(cw, metrics, meta); err != nil {
+		tmp.Close()
+		return 0, "", err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return 0, "", err
+	}
+	if err := tmp.Close(); err != nil {
+		return 0, "", err
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return 0, "", err
+	}
+	return cw.n, hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// readArchiveFile reads an archive after checking its checksum.
+func readArchiveFile(rec *storage.MetricsArchive) ([]*storage.MetricsSnapshot, error) {
+	data, err := os.ReadFile(rec.Path)
+	if err != nil {
+		return nil, err
+	}
+	sum := sha256.Sum256(data)
+	if rec.SHA256 != "" && hex.EncodeToString(sum[:]) != rec.SHA256 {
+		return nil, fmt.Errorf("archive %s: checksum mismatch", rec.Path)
+	}
+	return readMetrics(bytes.NewReader(data), int64(len(data)))
+}
+
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
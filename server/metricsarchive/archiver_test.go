@@ -0,0 +1,132 @@
+package metricsarchive
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	"printmaster/common/parquet"
+	"printmaster/server/storage"
+)
+
+func TestArchiveAndRestore(t *testing.T) {
+	t.Parallel()
+	store, err := storage.NewSQLiteStore(":memory:")
+	if err != nil {
+		t.Fatalf("NewSQLiteStore: %v", err)
+	}
+	defer store.Close()
+	ctx := context.Background()
+
+	now := time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)
+	if err := store.RegisterAgent(ctx, &storage.Agent{AgentID: "agent-1", Hostname: "pc", Token: "t", Status: "active", RegisteredAt: now, LastSeen: now}); err != nil {
+		t.Fatalf("RegisterAgent: %v", err)
+	}
+	dev := &storage.Device{AgentID: "agent-1"}
+	dev.Serial, dev.IP = "SER1", "10.0.0.5"
+	if err := store.UpsertDevice(ctx, dev); err != nil {
+		t.Fatalf("UpsertDevice: %v", err)
+	}
+
+	// Four readings a day for two old days, plus recent readings
+	old := time.Date(2022, 5, 10, 0, 0, 0, 0, time.UTC)
+	pages := 1000
+	for _, ts := range []time.Time{
+		old.Add(6 * time.Hour), old.Add(12 * time.Hour), old.Add(18 * time.Hour), old.Add(23*time.Hour + 1234567*time.Nanosecond),
+		old.Add(30 * time.Hour), old.Add(40 * time.Hour),
+		now.Add(-time.Hour),
+	} {
+		pages += 10
+		m := &storage.MetricsSnapshot{Serial: "SER1", AgentID: "agent-1", Timestamp: ts, PageCount: pages, DuplexSheets: pages / 4, TonerLevels: map[string]interface{}{"black": 50.0}}
+		if err := store.SaveMetrics(ctx, m); err != nil {
+			t.Fatalf("SaveMetrics: %v", err)
+		}
+	}
+
+	dir := t.TempDir()
+	a := New(store, Config{Dir: dir, MinAge: 3 * 365 * 24 * time.Hour, RestoreFor: time.Hour})
+	a.now = func() time.Time { return now }
+
+	archived, err := a.ArchiveDue(ctx)
+	if err != nil {
+		t.Fatalf("ArchiveDue: %v", err)
+	}
+	if len(archived) != 1 {
+		t.Fatalf("archived = %+v", archived)
+	}
+	rec := archived[0]
+	if rec.RowCount != 6 || rec.SummaryRows != 2 || rec.DeviceCount != 1 || rec.SHA256 == "" {
+		t.Errorf("archive record = %+v", rec)
+	}
+	if _, err := os.Stat(rec.Path); err != nil {
+		t.Fatalf("archive file: %v", err)
+	}
+
+	// Only the last reading of each day stays queryable
+	history, err := store.GetMetricsHistory(ctx, "SER1", time.Time{})
+	if err != nil {
+		t.Fatalf("GetMetricsHistory: %v", err)
+	}
+	if len(history) != 3 || history[0].PageCount != 1040 || history[1].PageCount != 1060 {
+		t.Fatalf("history after archive = %d rows (%+v)", len(history), history)
+	}
+
+	// A second run finds nothing new
+	if again, err := a.ArchiveDue(ctx); err != nil || len(again) != 0 {
+		t.Errorf("second ArchiveDue = %+v, %v", again, err)
+	}
+
+	rec, inserted, skipped, err := a.Restore(ctx, rec.ID)
+	if err != nil {
+		t.Fatalf("Restore: %v", err)
+	}
+	if inserted != 4 || skipped != 0 || rec.RestoredUntil == nil {
+		t.Errorf("restore inserted=%d skipped=%d rec=%+v", inserted, skipped, rec)
+	}
+	history, _ = store.GetMetricsHistory(ctx, "SER1", time.Time{})
+	if len(history) != 7 || history[0].TonerLevels["black"] != 50.0 || history[0].DuplexSheets != history[0].PageCount/4 {
+		t.Fatalf("history after restore = %d rows", len(history))
+	}
+
+	// Nothing expires until the restore window ends
+	if n, err := a.ExpireRestores(ctx); err != nil || n != 0 {
+		t.Errorf("early ExpireRestores = %d, %v", n, err)
+	}
+	a.now = func() time.Time { return now.Add(2 * time.Hour) }
+	if n, err := a.ExpireRestores(ctx); err != nil || n != 1 {
+		t.Fatalf("ExpireRestores = %d, %v", n, err)
+	}
+	history, _ = store.GetMetricsHistory(ctx, "SER1", time.Time{})
+	if len(history) != 3 {
+		t.Errorf("history after expiry = %d rows", len(history))
+	}
+	if got, _ := store.GetMetricsArchive(ctx, rec.ID); got == nil || got.RestoredUntil != nil {
+		t.Errorf("archive after expiry = %+v", got)
+	}
+
+	if _, _, _, err := a.Restore(ctx, 999); err != ErrNotFound {
+		t.Errorf("Restore unknown = %v, want ErrNotFound", err)
+	}
+}
+
+func TestReadLegacyArchive(t *testing.T) {
+	t.Parallel()
+	var buf bytes.Buffer
+	pw := parquet.NewWriter(&buf, legacyArchiveColumns)
+	ts := time.Date(2022, 5, 10, 6, 0, 0, 0, time.UTC)
+	if err := pw.Write([]any{"SER1", "agent-1", ts, int64(1000), int64(0), int64(1000), int64(5), nil}); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	if err := pw.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	metrics, err := readMetrics(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatalf("readMetrics: %v", err)
+	}
+	if len(metrics) != 1 || metrics[0].PageCount != 1000 || metrics[0].DuplexSheets != 0 {
+		t.Fatalf("legacy archive = %+v", metrics)
+	}
+}
@@ -0,0 +1,96 @@
+package metricsarchive
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+
+	"printmaster/common/parquet"
+	"printmaster/server/storage"
+)
+
+// legacyArchiveColumns is the schema of archives written before the sheet
+// counters were added; they are still readable.
+var legacyArchiveColumns = []parquet.Column{
+	{Name: "serial", Type: parquet.String},
+	{Name: "agent_id", Type: parquet.String},
+	{Name: "timestamp", Type: parquet.Timestamp},
+	{Name: "page_count", Type: parquet.Int64},
+	{Name: "color_pages", Type: parquet.Int64},
+	{Name: "mono_pages", Type: parquet.Int64},
+	{Name: "scan_count", Type: parquet.Int64},
+	{Name: "toner_levels", Type: parquet.String},
+}
+
+// archiveColumns is the archive file schema. Toner levels are kept as their
+// JSON text so any supply names survive.
+var archiveColumns = append(append([]parquet.Column{}, legacyArchiveColumns...),
+	parquet.Column{Name: "duplex_sheets", Type: parquet.Int64},
+	parquet.Column{Name: "simplex_sheets", Type: parquet.Int64},
+	parquet.Column{Name: "nup_sheets", Type: parquet.Int64},
+)
+
+// writeMetrics writes metrics as a GZIP-compressed Parquet file.
+func writeMetrics(w io.Writer, metrics []*storage.MetricsSnapshot, meta map[string]string) error {
+	pw := parquet.NewWriter(w, archiveColumns)
+	pw.Compression = parquet.Gzip
+	pw.CreatedBy = "printmaster-server"
+	pw.Metadata = meta
+	for _, m := range metrics {
+		var toner any
+		if len(m.TonerLevels) > 0 {
+			b, err := json.Marshal(m.TonerLevels)
+			if err != nil {
+				return fmt.Errorf("toner levels for %s: %w", m.Serial, err)
+			}
+			toner = string(b)
+		}
+		if err := pw.Write([]any{m.Serial, m.AgentID, m.Timestamp.UTC(),
+			int64(m.PageCount), int64(m.ColorPages), int64(m.MonoPages), int64(m.ScanCount), toner,
+			int64(m.DuplexSheets), int64(m.SimplexSheets), int64(m.NUpSheets)}); err != nil {
+			return err
+		}
+	}
+	return pw.Close()
+}
+
+// readMetrics reads a file written by writeMetrics.
+func readMetrics(r io.ReaderAt, size int64) ([]*storage.MetricsSnapshot, error) {
+	f, err := parquet.Read(r, size)
+	if err != nil {
+		return nil, err
+	}
+	schema := fmt.Sprint(f.Columns)
+	if schema != fmt.Sprint(archiveColumns) && schema != fmt.Sprint(legacyArchiveColumns) {
+		return nil, fmt.Errorf("unexpected archive schema %v", f.Columns)
+	}
+	metrics := make([]*storage.MetricsSnapshot, 0, len(f.Rows))
+	for _, row := range f.Rows {
+		m := &storage.MetricsSnapshot{}
+		m.Serial, _ = row[0].(string)
+		m.AgentID, _ = row[1].(string)
+		m.Timestamp, _ = row[2].(time.Time)
+		m.PageCount = archiveInt(row[3])
+		m.ColorPages = archiveInt(row[4])
+		m.MonoPages = archiveInt(row[5])
+		m.ScanCount = archiveInt(row[6])
+		if toner, _ := row[7].(string); toner != "" {
+			if err := json.Unmarshal([]byte(toner), &m.TonerLevels); err != nil {
+				return nil, fmt.Errorf("toner levels for %s: %w", m.Serial, err)
+			}
+		}
+		if len(row) > 8 {
+			m.DuplexSheets = archiveInt(row[8])
+			m.SimplexSheets = archiveInt(row[9])
+			m.NUpSheets = archiveInt(row[10])
+		}
+		metrics = append(metrics, m)
+	}
+	return metrics, nil
+}
+
+func archiveInt(v any) int {
+	n, _ := v.(int64)
+	return int(n)
+}
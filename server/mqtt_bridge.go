@@ -0,0 +1,305 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+
+	"printmaster/server/storage"
+)
+
+// Per-agent topic kinds, matching the agent's MQTT transport. Agents publish
+// to <prefix>/agents/<agent_id>/<kind> and receive commands on
+// <prefix>/agents/<agent_id>/commands.
+const (
+	mqttKindHeartbeat  = "heartbeat"
+	mqttKindDevices    = "devices"
+	mqttKindMetrics    = "metrics"
+	mqttKindMeterReads = "meter_reads"
+	mqttKindStatus     = "status"
+	mqttKindCommands   = "commands"
+)
+
+// errAgentNotConnected is returned when a command has no route to the agent.
+var errAgentNotConnected = errors.New("agent not connected")
+
+// activeMQTTBridge is set while the broker bridge runs.
+var activeMQTTBridge *mqttBridge
+
+// mqttBridge subscribes to the topics agents on the MQTT transport publish
+// to and feeds each message through the handlers HTTP uploads use, ingest
+// queues included. Only agents already registered with the server (joined
+// over HTTP) are accepted; the broker's ACLs decide who may publish as whom.
+type mqttBridge struct {
+	cfg    MQTTBridgeConfig
+	store  storage.Store
+	client mqtt.Client
+
+	// handlers maps a topic kind to the wrapped upload handler
+	handlers map[string]http.HandlerFunc
+
+	mu   sync.RWMutex
+	seen map[string]time.Time // agents heard from over MQTT
+}
+
+func newMQTTBridge(cfg MQTTBridgeConfig, store storage.Store, queues *ingestQueueSet) *mqttBridge {
+	cfg.TopicPrefix = strings.Trim(strings.TrimSpace(cfg.TopicPrefix), "/")
+	if cfg.TopicPrefix == "" {
+		cfg.TopicPrefix = "printmaster"
+	}
+	if cfg.ClientID == "" {
+		cfg.ClientID = "printmaster-server"
+	}
+	b := &mqttBridge{
+		cfg:   cfg,
+		store: store,
+		handlers: map[string]http.HandlerFunc{
+			mqttKindHeartbeat: handleAgentHeartbeat,
+		},
+		seen: make(map[string]time.Time),
+	}
+	if queues != nil {
+		b.handlers[mqttKindDevices] = queues.devices.Wrap(handleDevicesBatch)
+		b.handlers[mqttKindMetrics] = queues.metrics.Wrap(handleMetricsBatch)
+		b.handlers[mqttKindMeterReads] = queues.meterReads.Wrap(handleMeterReadsBatch)
+	}
+	return b
+}
+
+// startMQTTBridge connects to the configured broker. Connection and
+// subscription happen in the background and are retried until the broker is
+// reachable.
+func startMQTTBridge(cfg MQTTBridgeConfig, store storage.Store, queues *ingestQueueSet) (*mqttBridge, error) {
+	if strings.TrimSpace(cfg.Broker) == "" {
+		return nil, fmt.Errorf("mqtt bridge enabled but no broker configured")
+	}
+	if cfg.QoS < 0 || cfg.QoS > 2 {
+		return nil, fmt.Errorf("invalid mqtt qos %d", cfg.QoS)
+	}
+	tlsConfig, err := cfg.tlsConfig()
+	if err != nil {
+		return nil, err
+	}
+	b := newMQTTBridge(cfg, store, queues)
+	opts := mqtt.NewClientOptions().
+		AddBroker(strings.TrimSpace(cfg.Broker)).
+		SetClientID(b.cfg.ClientID).
+		SetUsername(cfg.Username).
+		SetPassword(cfg.Password).
+		SetCleanSession(cfg.CleanSession).
+		SetResumeSubs(!cfg.CleanSession).
+		SetAutoReconnect(true).
+		SetConnectRetry(true).
+		SetConnectRetryInterval(5 * time.Second).
+		SetMaxReconnectInterval(2 * time.Minute).
+		SetOrderMatters(false).
+		SetOnConnectHandler(b.onConnect).
+		SetConnectionLostHandler(func(_ mqtt.Client, err error) {
+			logWarn("MQTT bridge lost broker connection", "broker", cfg.Broker, "error", err)
+		})
+	if tlsConfig != nil {
+		opts.SetTLSConfig(tlsConfig)
+	}
+	b.client = mqtt.NewClient(opts)
+	b.client.Connect()
+	activeMQTTBridge = b
+	logInfo("MQTT bridge started", "broker", cfg.Broker, "topic_prefix", b.cfg.TopicPrefix)
+	return b, nil
+}
+
+func (b *mqttBridge) stop() {
+	if b == nil || b.client == nil {
+		return
+	}
+	b.client.Disconnect(250)
+	if activeMQTTBridge == b {
+		activeMQTTBridge = nil
+	}
+}
+
+func (b *mqttBridge) onConnect(client mqtt.Client) {
+	topic := b.cfg.TopicPrefix + "/agents/+/+"
+	token := client.Subscribe(topic, byte(b.cfg.QoS), func(_ mqtt.Client, msg mqtt.Message) {
+		b.handleMessage(msg.Topic(), msg.Payload())
+	})
+	go func() {
+		if token.WaitTimeout(10*time.Second) && token.Error() != nil {
+			logError("MQTT bridge failed to subscribe", "topic", topic, "error", token.Error())
+			return
+		}
+		logInfo("MQTT bridge connected", "broker", b.cfg.Broker, "topic", topic)
+	}()
+}
+
+// parseTopic splits <prefix>/agents/<agent_id>/<kind>.
+func (b *mqttBridge) parseTopic(topic string) (agentID, kind string, ok bool) {
+	rest, found := strings.CutPrefix(topic, b.cfg.TopicPrefix+"/agents/")
+	if !found {
+		return "", "", false
+	}
+	agentID, kind, found = strings.Cut(rest, "/")
+	if !found || agentID == "" || kind == "" || strings.Contains(kind, "/") {
+		return "", "", false
+	}
+	return agentID, kind, true
+}
+
+// handleMessage routes one agent message. Payloads carry the same JSON the
+// agent would POST over HTTP; the agent ID in the payload is replaced with
+// the one from the topic so an agent cannot report as another.
+func (b *mqttBridge) handleMessage(topic string, payload []byte) {
+	agentID, kind, ok := b.parseTopic(topic)
+	if !ok || kind == mqttKindCommands {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
+	defer cancel()
+
+	agent, err := b.store.GetAgent(ctx, agentID)
+	if err != nil || agent == nil {
+		logWarn("MQTT message from unregistered agent ignored", "agent_id", agentID, "kind", kind)
+		return
+	}
+	b.mu.Lock()
+	b.seen[agentID] = time.Now()
+	b.mu.Unlock()
+
+	if kind == mqttKindStatus {
+		b.handleStatus(ctx, agent, strings.TrimSpace(string(payload)))
+		return
+	}
+	handler, ok := b.handlers[kind]
+	if !ok {
+		logDebug("Ignoring MQTT message with unknown topic", "topic", topic)
+		return
+	}
+
+	var body map[string]interface{}
+	if err := json.Unmarshal(payload, &body); err != nil {
+		logWarn("Invalid JSON in MQTT message", "agent_id", agentID, "kind", kind, "error", err)
+		return
+	}
+	body["agent_id"] = agentID
+	// Rotated tokens cannot be handed back over MQTT
+	delete(body, "token_rotation")
+	if _, ok := body["timestamp"]; !ok {
+		body["timestamp"] = time.Now().UTC()
+	}
+	encoded, err := json.Marshal(body)
+	if err != nil {
+		return
+	}
+
+	req, err := http.NewRequestWithContext(context.WithValue(ctx, agentContextKey, agent), http.MethodPost, "/mqtt/"+kind, bytes.NewReader(encoded))
+	if err != nil {
+		return
+	}
+	req.Header.Set("Content-Type", "application/json")
+	rr := httptest.NewRecorder()
+	handler(rr, req)
+	if rr.Code >= http.StatusBadRequest {
+		logWarn("MQTT message rejected", "agent_id", agentID, "kind", kind, "status", rr.Code, "response", strings.TrimSpace(rr.Body.String()))
+	}
+}
+
+// handleStatus records the retained online/offline status agents publish,
+// including the broker's last-will "offline" when an agent drops.
+func (b *mqttBridge) handleStatus(ctx context.Context, agent *storage.Agent, status string) {
+	switch status {
+	case "online":
+		status = "active"
+	case "offline":
+	default:
+		return
+	}
+	if err := b.store.UpdateAgentHeartbeat(ctx, agent.AgentID, status); err != nil {
+		logWarn("Failed to update agent status from MQTT", "agent_id", agent.AgentID, "error", err)
+		return
+	}
+	sseHub.Broadcast(SSEEvent{
+		Type: "agent_heartbeat",
+		Data: map[string]interface{}{
+			"agent_id": agent.AgentID,
+			"status":   status,
+		},
+	})
+}
+
+// handles reports whether the agent has been heard from over MQTT.
+func (b *mqttBridge) handles(agentID string) bool {
+	if b == nil {
+		return false
+	}
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	_, ok := b.seen[agentID]
+	return ok
+}
+
+// publishCommand queues a command on the agent's command topic. With a
+// persistent agent session the broker holds it until the agent reconnects.
+func (b *mqttBridge) publishCommand(agentID, command string, data map[string]interface{}) error {
+	msg := map[string]interface{}{"command": command}
+	for k, v := range data {
+		msg[k] = v
+	}
+	payload, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	token := b.client.Publish(b.cfg.TopicPrefix+"/agents/"+agentID+"/"+mqttKindCommands, byte(b.cfg.QoS), false, payload)
+	if !token.WaitTimeout(10 * time.Second) {
+		return fmt.Errorf("mqtt publish timed out")
+	}
+	return token.Error()
+}
+
+// sendAgentCommand delivers a command over the agent's WebSocket, or through
+// the MQTT bridge for agents using the MQTT transport.
+func sendAgentCommand(agentID, command string, data map[string]interface{}) error {
+	if conn, ok := getAgentWSConnection(agentID); ok {
+		return writeAgentCommand(conn, command, data)
+	}
+	if bridge := activeMQTTBridge; bridge.handles(agentID) {
+		return bridge.publishCommand(agentID, command, data)
+	}
+	return errAgentNotConnected
+}
+
+func (c MQTTBridgeConfig) tlsConfig() (*tls.Config, error) {
+	if c.CAPath == "" && c.CertPath == "" && !c.InsecureSkipVerify {
+		return nil, nil
+	}
+	tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12, InsecureSkipVerify: c.InsecureSkipVerify}
+	if c.CAPath != "" {
+		pemData, err := os.ReadFile(c.CAPath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read MQTT CA certificate: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pemData) {
+			return nil, fmt.Errorf("failed to parse MQTT CA certificate")
+		}
+		tlsConfig.RootCAs = pool
+	}
+	if c.CertPath != "" {
+		cert, err := tls.LoadX509KeyPair(c.CertPath, c.KeyPath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load MQTT client certificate: %w", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+	return tlsConfig, nil
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"printmaster/server/storage"
+)
+
+func TestMQTTBridgeRoutesAgentMessages(t *testing.T) {
+	store := SetupTestStore(t)
+	ctx := context.Background()
+	if err := store.RegisterAgent(ctx, &storage.Agent{AgentID: "agent-a", Hostname: "a", Token: "ta", TenantID: "tenant-a",
+		Status: "active", RegisteredAt: time.Now(), LastSeen: time.Now()}); err != nil {
+		t.Fatalf("RegisterAgent: %v", err)
+	}
+	queues := setupIngestQueues(&Config{Ingest: IngestConfig{Workers: 1, QueueSize: 10, MaxWaitSeconds: 5}})
+	t.Cleanup(func() {
+		queues.devices.Close()
+		queues.metrics.Close()
+		queues.meterReads.Close()
+		activeIngestQueues = nil
+	})
+	bridge := newMQTTBridge(MQTTBridgeConfig{TopicPrefix: "/fleet/"}, store, queues)
+
+	for _, topic := range []string{"fleet/agents/agent-a", "fleet/agents//devices", "other/agents/agent-a/devices", "fleet/agents/agent-a/devices/x"} {
+		if _, _, ok := bridge.parseTopic(topic); ok {
+			t.Fatalf("expected %q to be rejected", topic)
+		}
+	}
+
+	// The agent ID comes from the topic, not the payload
+	bridge.handleMessage("fleet/agents/agent-a/devices", []byte(`{"agent_id":"agent-b","devices":[{"serial":"SN1","ip":"10.0.0.5"}]}`))
+	dev, err := store.GetDevice(ctx, "SN1")
+	if err != nil || dev == nil || dev.AgentID != "agent-a" {
+		t.Fatalf("expected SN1 stored for agent-a, got %+v (%v)", dev, err)
+	}
+
+	bridge.handleMessage("fleet/agents/agent-a/status", []byte("offline"))
+	if agent, _ := store.GetAgent(ctx, "agent-a"); agent.Status != "offline" {
+		t.Fatalf("expected agent offline, got %q", agent.Status)
+	}
+	bridge.handleMessage("fleet/agents/agent-a/heartbeat", []byte(`{"status":"active","version":"1.2.3","hostname":"a"}`))
+	if agent, _ := store.GetAgent(ctx, "agent-a"); agent.Status != "active" || agent.Version != "1.2.3" {
+		t.Fatalf("heartbeat not applied: %+v", agent)
+	}
+
+	// Messages from agents the server does not know are dropped
+	bridge.handleMessage("fleet/agents/stranger/devices", []byte(`{"devices":[{"serial":"SN2"}]}`))
+	if dev, _ := store.GetDevice(ctx, "SN2"); dev != nil {
+		t.Fatalf("device from unregistered agent stored: %+v", dev)
+	}
+
+	if !bridge.handles("agent-a") || bridge.handles("stranger") {
+		t.Fatal("bridge should only claim agents it has heard from")
+	}
+	if err := sendAgentCommand("agent-z", "restart", nil); !errors.Is(err, errAgentNotConnected) {
+		t.Fatalf("expected errAgentNotConnected, got %v", err)
+	}
+}
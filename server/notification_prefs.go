@@ -0,0 +1,75 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"printmaster/server/storage"
+)
+
+// notificationPrefsResponse is the signed-in user's notification preferences
+// with the account address email falls back to.
+type notificationPrefsResponse struct {
+	*storage.UserNotificationPrefs
+	AccountEmail string `json:"account_email,omitempty"`
+}
+
+// handleMyNotificationPrefs reads (GET) or replaces (PUT) the signed-in
+// user's alert notification preferences. Users who never saved any get the
+// disabled defaults.
+func handleMyNotificationPrefs(w http.ResponseWriter, r *http.Request) {
+	principal := getPrincipal(r)
+	if principal == nil || principal.User == nil {
+		http.Error(w, "unauthenticated", http.StatusUnauthorized)
+		return
+	}
+	user := principal.User
+	ctx := r.Context()
+
+	switch r.Method {
+	case http.MethodGet:
+		prefs, err := serverStore.GetUserNotificationPrefs(ctx, user.ID)
+		if err != nil {
+			logError("Failed to load notification preferences", "user", user.Username, "error", err)
+			http.Error(w, "failed to load notification preferences", http.StatusInternalServerError)
+			return
+		}
+		if prefs == nil {
+			prefs = storage.DefaultUserNotificationPrefs(user.ID)
+		}
+		writeNotificationPrefs(w, user, prefs)
+	case http.MethodPut:
+		prefs := storage.DefaultUserNotificationPrefs(user.ID)
+		if err := decodeJSONBody(r, prefs); err != nil {
+			http.Error(w, "invalid JSON", http.StatusBadRequest)
+			return
+		}
+		prefs.UserID = user.ID
+		if err := prefs.Validate(); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if prefs.Enabled && prefs.EmailEnabled && prefs.EmailAddress == "" && user.Email == "" {
+			http.Error(w, "your account has no email address; enter one to receive email", http.StatusBadRequest)
+			return
+		}
+		if err := serverStore.UpsertUserNotificationPrefs(ctx, prefs); err != nil {
+			logError("Failed to save notification preferences", "user", user.Username, "error", err)
+			http.Error(w, "failed to save notification preferences", http.StatusInternalServerError)
+			return
+		}
+		logInfo("Notification preferences updated", "user", user.Username, "enabled", prefs.Enabled, "frequency", prefs.Frequency)
+		stored, err := serverStore.GetUserNotificationPrefs(ctx, user.ID)
+		if err != nil || stored == nil {
+			stored = prefs
+		}
+		writeNotificationPrefs(w, user, stored)
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func writeNotificationPrefs(w http.ResponseWriter, user *storage.User, prefs *storage.UserNotificationPrefs) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(notificationPrefsResponse{UserNotificationPrefs: prefs, AccountEmail: user.Email})
+}
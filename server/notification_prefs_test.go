@@ -0,0 +1,58 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"printmaster/server/storage"
+)
+
+func TestMyNotificationPrefs(t *testing.T) {
+	SetupTestStore(t)
+	user := NewTestUser(storage.RoleViewer, "tenant-a")
+
+	call := func(method, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, "/api/v1/users/me/notification-preferences", strings.NewReader(body))
+		rr := httptest.NewRecorder()
+		handleMyNotificationPrefs(rr, InjectTestUser(req, user))
+		return rr
+	}
+
+	rr := call(http.MethodGet, "")
+	if rr.Code != http.StatusOK {
+		t.Fatalf("get defaults: expected 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	var prefs storage.UserNotificationPrefs
+	if err := json.Unmarshal(rr.Body.Bytes(), &prefs); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if prefs.Enabled || prefs.Frequency != storage.NotifyImmediate || prefs.MinSeverity != "warning" {
+		t.Errorf("defaults = %+v", prefs)
+	}
+
+	// No account address and none given
+	if rr := call(http.MethodPut, `{"enabled":true,"email_enabled":true}`); rr.Code != http.StatusBadRequest {
+		t.Errorf("email without address: expected 400, got %d", rr.Code)
+	}
+	if rr := call(http.MethodPut, `{"enabled":true,"frequency":"monthly","email_address":"me@example.com"}`); rr.Code != http.StatusBadRequest {
+		t.Errorf("bad frequency: expected 400, got %d", rr.Code)
+	}
+
+	rr = call(http.MethodPut, `{"enabled":true,"min_severity":"critical","alert_types":["paper_jam"],"email_enabled":false,
+		"webhook_url":"https://hooks.example.com/me","frequency":"daily","digest_hour":6}`)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("put: expected 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	rr = call(http.MethodGet, "")
+	prefs = storage.UserNotificationPrefs{}
+	if err := json.Unmarshal(rr.Body.Bytes(), &prefs); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if !prefs.Enabled || prefs.UserID != user.ID || prefs.Frequency != storage.NotifyDaily || prefs.DigestHour != 6 ||
+		prefs.WebhookURL != "https://hooks.example.com/me" || len(prefs.AlertTypes) != 1 {
+		t.Errorf("saved prefs = %+v", prefs)
+	}
+}
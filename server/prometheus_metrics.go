@@ -0,0 +1,215 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"sort"
+	"time"
+
+	"printmaster/common/promexport"
+	authz "printmaster/server/authz"
+	metricsapi "printmaster/server/metrics"
+	"printmaster/server/scanmail"
+	"printmaster/server/storage"
+)
+
+// newPrometheusHandler serves fleet metrics at GET /metrics. Scrapers from
+// allowedNetworks see every tenant without credentials; anyone else needs a
+// session or API token and only sees their own tenants. The address matched
+// is the one the server's trusted proxies vouch for, never a client-supplied
+// forwarding header.
+func newPrometheusHandler(allowedNetworks []*net.IPNet) http.HandlerFunc {
+	authed := requireWebAuth(func(w http.ResponseWriter, r *http.Request) {
+		if !authorizeOrReject(w, r, authz.ActionMetricsSummaryRead, authz.ResourceRef{}) {
+			return
+		}
+		principal := getPrincipal(r)
+		if principal == nil {
+			http.Error(w, "unauthenticated", http.StatusUnauthorized)
+			return
+		}
+		scope, ok := tenantScope(principal)
+		if !ok {
+			http.Error(w, "forbidden", http.StatusForbidden)
+			return
+		}
+		servePrometheusMetrics(w, r, scope)
+	})
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			http.Error(w, "GET only", http.StatusMethodNotAllowed)
+			return
+		}
+		if ip := net.ParseIP(getTrustedHopIP(r)); ip != nil {
+			for _, n := range allowedNetworks {
+				if n.Contains(ip) {
+					servePrometheusMetrics(w, r, nil)
+					return
+				}
+			}
+		}
+		authed(w, r)
+	}
+}
+
+// prometheusAllowedNetworks parses prometheus.allowed_networks. Invalid
+// entries disable the allowlist so a typo never opens the endpoint wider.
+func prometheusAllowedNetworks(cfg PrometheusConfig) []*net.IPNet {
+	networks, err := scanmail.ParseNetworks(cfg.AllowedNetworks)
+	if err != nil {
+		logError("Invalid prometheus allowed_networks; scrapes need an API token", "error", err)
+		return nil
+	}
+	return networks
+}
+
+func servePrometheusMetrics(w http.ResponseWriter, r *http.Request, scope map[string]struct{}) {
+	ctx, cancel := context.WithTimeout(r.Context(), 15*time.Second)
+	defer cancel()
+	families, err := fleetPrometheusFamilies(ctx, scope, time.Now())
+	if err != nil {
+		logError("Failed to build Prometheus metrics", "error", err)
+		http.Error(w, "failed to build metrics", http.StatusInternalServerError)
+		return
+	}
+	write := promexport.Write
+	if promexport.WantsOpenMetrics(r.Header.Get("Accept")) {
+		w.Header().Set("Content-Type", promexport.ContentTypeOpenMetrics)
+		write = promexport.WriteOpenMetrics
+	} else {
+		w.Header().Set("Content-Type", promexport.ContentType)
+	}
+	if err := write(w, families); err != nil {
+		logDebug("Prometheus scrape aborted", "error", err)
+	}
+}
+
+// fleetPrometheusFamilies builds the fleet metrics visible to scope (nil for
+// all tenants). Server-wide metrics such as ingest queues are only included
+// for unscoped callers.
+func fleetPrometheusFamilies(ctx context.Context, scope map[string]struct{}, now time.Time) ([]*promexport.Family, error) {
+	inScope := func(tenantID string) bool { return tenantAllowed(scope, tenantID) }
+
+	agents, err := serverStore.ListAgents(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("list agents: %w", err)
+	}
+	sort.Slice(agents, func(i, j int) bool { return agents[i].AgentID < agents[j].AgentID })
+
+	agentCounts := promexport.NewGauge("printmaster_agents",
+		"Agents by tenant and state (connected or disconnected).")
+	agentUp := promexport.NewGauge("printmaster_agent_up",
+		"1 if the agent is connected over WebSocket or checked in recently.")
+	agentSeen := promexport.NewGauge("printmaster_agent_last_seen_timestamp_seconds",
+		"Unix time the agent last contacted the server.")
+	uploadLag := promexport.NewGauge("printmaster_agent_upload_lag_seconds",
+		"Seconds since the agent's last upload, by kind (devices or metrics).")
+
+	agentTenant := make(map[string]string, len(agents))
+	counts := map[[2]string]float64{}
+	for _, a := range agents {
+		if a == nil || !inScope(a.TenantID) {
+			continue
+		}
+		agentTenant[a.AgentID] = a.TenantID
+		state := "connected"
+		if deriveAgentConnectionType(a) == "none" {
+			state = "disconnected"
+		}
+		counts[[2]string{a.TenantID, state}]++
+		agentUp.Add(boolValue(state == "connected"), "agent_id", a.AgentID, "tenant_id", a.TenantID)
+		if !a.LastSeen.IsZero() {
+			agentSeen.Add(float64(a.LastSeen.Unix()), "agent_id", a.AgentID, "tenant_id", a.TenantID)
+		}
+		for _, sync := range []struct {
+			kind string
+			at   time.Time
+		}{{"devices", a.LastDeviceSync}, {"metrics", a.LastMetricsSync}} {
+			if !sync.at.IsZero() {
+				uploadLag.Add(now.Sub(sync.at).Seconds(), "agent_id", a.AgentID, "tenant_id", a.TenantID, "kind", sync.kind)
+			}
+		}
+	}
+	addTenantCounts(agentCounts, counts, "state")
+
+	devices, err := serverStore.ListAllDevices(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("list devices: %w", err)
+	}
+	deviceCounts := promexport.NewGauge("printmaster_devices",
+		"Devices by tenant and state (online or offline).")
+	counts = map[[2]string]float64{}
+	for _, d := range filterApprovedDevices(ctx, serverStore, devices) {
+		if d == nil {
+			continue
+		}
+		tenantID, ok := agentTenant[d.AgentID]
+		if !ok {
+			continue
+		}
+		state := "online"
+		if now.Sub(d.LastSeen) >= metricsapi.OfflineAfter {
+			state = "offline"
+		}
+		counts[[2]string{tenantID, state}]++
+	}
+	addTenantCounts(deviceCounts, counts, "state")
+
+	alerts, err := serverStore.ListActiveAlerts(ctx, storage.AlertFilters{TopLevel: true})
+	if err != nil {
+		return nil, fmt.Errorf("list alerts: %w", err)
+	}
+	alertCounts := promexport.NewGauge("printmaster_alerts_open",
+		"Active or acknowledged alerts by tenant and severity.")
+	counts = map[[2]string]float64{}
+	for _, a := range alerts {
+		if inScope(a.TenantID) {
+			counts[[2]string{a.TenantID, a.Severity}]++
+		}
+	}
+	addTenantCounts(alertCounts, counts, "severity")
+
+	families := []*promexport.Family{agentCounts, deviceCounts, alertCounts, agentUp, agentSeen, uploadLag}
+	if scope == nil {
+		info := promexport.NewGauge("printmaster_server_info", "Server version; always 1.")
+		info.Add(1, "version", Version)
+		families = append([]*promexport.Family{info}, families...)
+		if activeIngestQueues != nil {
+			depth := promexport.NewGauge("printmaster_ingest_queue_depth", "Uploads waiting for an ingest worker.")
+			rejected := promexport.NewCounter("printmaster_ingest_rejected_total",
+				"Uploads turned away because the ingest queue was full.")
+			for _, q := range activeIngestQueues.stats() {
+				depth.Add(float64(q.QueueDepth), "queue", q.Name)
+				rejected.Add(float64(q.Rejected), "queue", q.Name)
+			}
+			families = append(families, depth, rejected)
+		}
+	}
+	return families, nil
+}
+
+// addTenantCounts adds counts keyed by (tenant, value) in a stable order.
+func addTenantCounts(f *promexport.Family, counts map[[2]string]float64, label string) {
+	keys := make([][2]string, 0, len(counts))
+	for k := range counts {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i][0] != keys[j][0] {
+			return keys[i][0] < keys[j][0]
+		}
+		return keys[i][1] < keys[j][1]
+	})
+	for _, k := range keys {
+		f.Add(counts[k], "tenant_id", k[0], label, k[1])
+	}
+}
+
+func boolValue(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"printmaster/common/promexport"
+	"printmaster/server/storage"
+)
+
+func TestPrometheusMetricsEndpoint(t *testing.T) {
+	store := SetupTestStore(t)
+	ctx := context.Background()
+	now := time.Now()
+	for _, a := range []*storage.Agent{
+		{AgentID: "agent-a", Hostname: "a", Token: "ta", TenantID: "tenant-a", Status: "active", RegisteredAt: now, LastSeen: now},
+		{AgentID: "agent-b", Hostname: "b", Token: "tb", TenantID: "tenant-b", Status: "active", RegisteredAt: now, LastSeen: now.Add(-time.Hour)},
+	} {
+		if err := store.RegisterAgent(ctx, a); err != nil {
+			t.Fatalf("RegisterAgent: %v", err)
+		}
+	}
+	for _, d := range []struct {
+		serial, agentID string
+		lastSeen        time.Time
+	}{
+		{"SN-A1", "agent-a", now},
+		{"SN-A2", "agent-a", now.Add(-time.Hour)},
+		{"SN-B1", "agent-b", now},
+	} {
+		dev := &storage.Device{}
+		dev.Serial, dev.AgentID, dev.LastSeen = d.serial, d.agentID, d.lastSeen
+		if err := store.UpsertDevice(ctx, dev); err != nil {
+			t.Fatalf("UpsertDevice: %v", err)
+		}
+	}
+	for _, a := range []storage.Alert{
+		{Severity: "critical", TenantID: "tenant-a", Status: string(storage.AlertStatusActive)},
+		{Severity: "warning", TenantID: "tenant-b", Status: string(storage.AlertStatusActive)},
+	} {
+		a.Type, a.Scope, a.Title, a.TriggeredAt = "device_error", "device", "Error", now
+		if _, err := store.CreateAlert(ctx, &a); err != nil {
+			t.Fatalf("CreateAlert: %v", err)
+		}
+	}
+
+	handler := newPrometheusHandler(prometheusAllowedNetworks(PrometheusConfig{AllowedNetworks: []string{"10.0.5.0/24"}}))
+	scrape := func(remote, accept string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+		req.RemoteAddr = remote + ":40000"
+		req.Header.Set("Accept", accept)
+		rr := httptest.NewRecorder()
+		handler(rr, req)
+		return rr
+	}
+
+	rr := scrape("10.0.5.20", "text/plain")
+	if rr.Code != http.StatusOK || rr.Header().Get("Content-Type") != promexport.ContentType {
+		t.Fatalf("allowlisted scrape: %d %q", rr.Code, rr.Header().Get("Content-Type"))
+	}
+	out := rr.Body.String()
+	for _, want := range []string{
+		`printmaster_agents{tenant_id="tenant-a",state="connected"} 1`,
+		`printmaster_agents{tenant_id="tenant-b",state="disconnected"} 1`,
+		`printmaster_devices{tenant_id="tenant-a",state="offline"} 1`,
+		`printmaster_devices{tenant_id="tenant-a",state="online"} 1`,
+		`printmaster_alerts_open{tenant_id="tenant-b",severity="warning"} 1`,
+		`printmaster_agent_up{agent_id="agent-b",tenant_id="tenant-b"} 0`,
+		`printmaster_server_info{version=`,
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("missing %q in:\n%s", want, out)
+		}
+	}
+
+	if rr := scrape("10.0.5.20", "application/openmetrics-text; version=1.0.0"); !strings.HasSuffix(rr.Body.String(), "# EOF\n") {
+		t.Errorf("expected OpenMetrics output, got:\n%s", rr.Body.String())
+	}
+	if rr := scrape("10.0.6.20", "text/plain"); rr.Code != http.StatusUnauthorized {
+		t.Errorf("scrape without token from outside the allowlist: got %d", rr.Code)
+	}
+
+	// Behind a proxy the client controls the left of X-Forwarded-For
+	oldConfig := serverConfig
+	serverConfig = &Config{Server: ServerConfig{BehindProxy: true}}
+	parsedTrustedProxies = nil
+	trustedProxiesOnce = sync.Once{}
+	defer func() {
+		serverConfig = oldConfig
+		parsedTrustedProxies = nil
+		trustedProxiesOnce = sync.Once{}
+	}()
+	proxied := func(xff string) int {
+		req := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+		req.RemoteAddr = "127.0.0.1:40000"
+		req.Header.Set("X-Forwarded-For", xff)
+		rr := httptest.NewRecorder()
+		handler(rr, req)
+		return rr.Code
+	}
+	if code := proxied("10.0.5.20, 203.0.113.9"); code != http.StatusUnauthorized {
+		t.Errorf("spoofed X-Forwarded-For: got %d, want 401", code)
+	}
+	if code := proxied("10.0.5.20"); code != http.StatusOK {
+		t.Errorf("allowlisted scraper behind the proxy: got %d, want 200", code)
+	}
+
+	if nets := prometheusAllowedNetworks(PrometheusConfig{AllowedNetworks: []string{"10.0.5.0/99"}}); nets != nil {
+		t.Errorf("invalid allowlist should be ignored, got %v", nets)
+	}
+
+	// Tenant-scoped callers see only their tenants and no server-wide metrics
+	families, err := fleetPrometheusFamilies(ctx, map[string]struct{}{"tenant-a": {}}, now)
+	if err != nil {
+		t.Fatalf("fleetPrometheusFamilies: %v", err)
+	}
+	var b strings.Builder
+	promexport.Write(&b, families)
+	if scoped := b.String(); strings.Contains(scoped, "tenant-b") || strings.Contains(scoped, "printmaster_server_info") {
+		t.Errorf("tenant-a scrape leaked data:\n%s", scoped)
+	}
+}
@@ -0,0 +1,326 @@
+package relay
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"printmaster/server/storage"
+)
+
+// Config configures a relay node.
+type Config struct {
+	CentralURL        string        // Base URL of the central server
+	Token             string        // Relay token issued when the relay was registered
+	Version           string        // Reported with heartbeats
+	FlushInterval     time.Duration // How often queued uploads are forwarded (default 5s)
+	HeartbeatInterval time.Duration // Default 30s
+	MaxQueue          int           // Uploads held while the central server is unreachable (default 10000)
+	MaxBatch          int           // Uploads per forwarded batch (default 200)
+	MaxBodyBytes      int64         // Largest accepted agent upload (default 32 MiB)
+	Client            *http.Client
+	Logger            *slog.Logger
+}
+
+// Node is a relay: it queues agent uploads and forwards them to the central
+// server in gzip-compressed batches.
+type Node struct {
+	cfg     Config
+	central string
+
+	mu    sync.Mutex
+	queue []Item
+
+	flushMu   sync.Mutex
+	wake      chan struct{}
+	forwarded atomic.Int64
+	dropped   atomic.Int64
+}
+
+// NewNode validates cfg and returns a relay node.
+func NewNode(cfg Config) (*Node, error) {
+	u, err := url.Parse(strings.TrimSpace(cfg.CentralURL))
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("central URL must be an absolute http(s) URL")
+	}
+	if strings.TrimSpace(cfg.Token) == "" {
+		return nil, fmt.Errorf("relay token is required")
+	}
+	if cfg.FlushInterval <= 0 {
+		cfg.FlushInterval = 5 * time.Second
+	}
+	if cfg.HeartbeatInterval <= 0 {
+		cfg.HeartbeatInterval = 30 * time.Second
+	}
+	if cfg.MaxQueue <= 0 {
+		cfg.MaxQueue = 10000
+	}
+	if cfg.MaxBatch <= 0 {
+		cfg.MaxBatch = 200
+	}
+	if cfg.MaxBodyBytes <= 0 {
+		cfg.MaxBodyBytes = 32 << 20
+	}
+	if cfg.Client == nil {
+		cfg.Client = &http.Client{Timeout: 60 * time.Second}
+	}
+	if cfg.Logger == nil {
+		cfg.Logger = slog.Default()
+	}
+	return &Node{
+		cfg:     cfg,
+		central: strings.TrimRight(u.String(), "/"),
+		wake:    make(chan struct{}, 1),
+	}, nil
+}
+
+// QueueDepth returns the number of uploads waiting to be forwarded.
+func (n *Node) QueueDepth() int {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return len(n.queue)
+}
+
+// Handler serves the health probe and the agent upload paths.
+func (n *Node) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc(HealthPath, n.handleHealth)
+	for _, p := range ForwardPaths {
+		mux.HandleFunc(p, n.handleUpload)
+	}
+	return mux
+}
+
+func (n *Node) handleHealth(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		http.Error(w, "GET only", http.StatusMethodNotAllowed)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status":      "ok",
+		"version":     n.cfg.Version,
+		"queue_depth": n.QueueDepth(),
+	})
+}
+
+func (n *Node) handleUpload(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "POST only", http.StatusMethodNotAllowed)
+		return
+	}
+	auth := r.Header.Get("Authorization")
+	if !strings.HasPrefix(auth, "Bearer ") {
+		http.Error(w, "Missing Authorization header", http.StatusUnauthorized)
+		return
+	}
+	body, err := io.ReadAll(io.LimitReader(r.Body, n.cfg.MaxBodyBytes+1))
+	if err != nil {
+		http.Error(w, "failed to read body", http.StatusBadRequest)
+		return
+	}
+	if int64(len(body)) > n.cfg.MaxBodyBytes {
+		http.Error(w, "request too large", http.StatusRequestEntityTooLarge)
+		return
+	}
+	if !json.Valid(body) {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+	remoteIP, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		remoteIP = r.RemoteAddr
+	}
+
+	n.mu.Lock()
+	full := len(n.queue) >= n.cfg.MaxQueue
+	if !full {
+		n.queue = append(n.queue, Item{
+			Path:          r.URL.Path,
+			Authorization: auth,
+			RemoteIP:      remoteIP,
+			ReceivedAt:    time.Now().UTC(),
+			Body:          body,
+		})
+	}
+	depth := len(n.queue)
+	n.mu.Unlock()
+
+	if full {
+		// Agents fall back to the central server when the relay refuses
+		n.dropped.Add(1)
+		w.Header().Set("Retry-After", "30")
+		http.Error(w, "relay queue full", http.StatusServiceUnavailable)
+		return
+	}
+	if depth >= n.cfg.MaxBatch {
+		select {
+		case n.wake <- struct{}{}:
+		default:
+		}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(map[string]interface{}{"success": true, "queued": true})
+}
+
+// Run forwards queued uploads and sends heartbeats until ctx is done, then
+// makes a last attempt to forward what is left.
+func (n *Node) Run(ctx context.Context) {
+	flush := time.NewTicker(n.cfg.FlushInterval)
+	defer flush.Stop()
+	heartbeat := time.NewTicker(n.cfg.HeartbeatInterval)
+	defer heartbeat.Stop()
+
+	n.sendHeartbeat(ctx)
+	for {
+		select {
+		case <-ctx.Done():
+			final, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			if err := n.Flush(final); err != nil {
+				n.cfg.Logger.Warn("Relay: final flush failed", "queued", n.QueueDepth(), "error", err)
+			}
+			cancel()
+			return
+		case <-flush.C:
+		case <-n.wake:
+		case <-heartbeat.C:
+			n.sendHeartbeat(ctx)
+			continue
+		}
+		if err := n.Flush(ctx); err != nil {
+			n.cfg.Logger.Warn("Relay: forwarding to central server failed", "queued", n.QueueDepth(), "error", err)
+		}
+	}
+}
+
+func (n *Node) sendHeartbeat(ctx context.Context) {
+	if err := n.Heartbeat(ctx); err != nil {
+		n.cfg.Logger.Warn("Relay: heartbeat failed", "error", err)
+	}
+}
+
+// errRetryLater stops a flush when the central server asked for some items to
+// be sent again.
+var errRetryLater = errors.New("central server deferred part of the batch")
+
+// Flush forwards queued uploads in batches until the queue is empty or the
+// central server cannot take more. Items the central server rejects are
+// dropped; items it defers (429, 5xx) stay queued.
+func (n *Node) Flush(ctx context.Context) error {
+	n.flushMu.Lock()
+	defer n.flushMu.Unlock()
+	for {
+		n.mu.Lock()
+		count := len(n.queue)
+		if count > n.cfg.MaxBatch {
+			count = n.cfg.MaxBatch
+		}
+		batch := append([]Item(nil), n.queue[:count]...)
+		n.mu.Unlock()
+		if len(batch) == 0 {
+			return nil
+		}
+
+		results, err := n.post(ctx, batch)
+		if err != nil {
+			return err
+		}
+		var retry []Item
+		for i, item := range batch {
+			res := ItemResult{Status: http.StatusBadGateway, Error: "missing result"}
+			if i < len(results) {
+				res = results[i]
+			}
+			switch {
+			case res.Status >= 200 && res.Status < 300:
+				n.forwarded.Add(1)
+			case res.Status == http.StatusTooManyRequests || res.Status >= 500:
+				retry = append(retry, item)
+			default:
+				n.dropped.Add(1)
+				n.cfg.Logger.Warn("Relay: central server rejected upload", "path", item.Path, "agent_ip", item.RemoteIP, "status", res.Status, "error", res.Error)
+			}
+		}
+
+		// Only Flush removes items, always from the front
+		n.mu.Lock()
+		n.queue = append(retry, n.queue[len(batch):]...)
+		n.mu.Unlock()
+		if len(retry) > 0 {
+			return errRetryLater
+		}
+	}
+}
+
+func (n *Node) post(ctx context.Context, items []Item) ([]ItemResult, error) {
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	if err := json.NewEncoder(gz).Encode(Batch{Items: items}); err != nil {
+		return nil, err
+	}
+	if err := gz.Close(); err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, n.central+IngestPath, &buf)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Content-Encoding", "gzip")
+	var resp IngestResponse
+	if err := n.do(req, &resp); err != nil {
+		return nil, err
+	}
+	return resp.Results, nil
+}
+
+// Heartbeat reports the relay's status to the central server.
+func (n *Node) Heartbeat(ctx context.Context) error {
+	payload, err := json.Marshal(storage.RelayHeartbeat{
+		Version:        n.cfg.Version,
+		QueueDepth:     int64(n.QueueDepth()),
+		ForwardedTotal: n.forwarded.Load(),
+		DroppedTotal:   n.dropped.Load(),
+	})
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, n.central+HeartbeatPath, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	return n.do(req, nil)
+}
+
+func (n *Node) do(req *http.Request, out interface{}) error {
+	req.Header.Set("Authorization", "Bearer "+n.cfg.Token)
+	req.Header.Set("User-Agent", "PrintMaster-Relay/"+n.cfg.Version)
+	resp, err := n.cfg.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("%s returned %d: %s", req.URL.Path, resp.StatusCode, strings.TrimSpace(string(msg)))
+	}
+	if out == nil {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
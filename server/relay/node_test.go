@@ -0,0 +1,134 @@
+package relay
+
+import (
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"printmaster/server/storage"
+)
+
+func TestNodeQueuesAndForwards(t *testing.T) {
+	t.Parallel()
+
+	var batches []Batch
+	var heartbeat storage.RelayHeartbeat
+	central := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer relay-token" {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		switch r.URL.Path {
+		case HeartbeatPath:
+			json.NewDecoder(r.Body).Decode(&heartbeat)
+		case IngestPath:
+			if r.Header.Get("Content-Encoding") != "gzip" {
+				t.Errorf("batch not compressed")
+			}
+			gz, err := gzip.NewReader(r.Body)
+			if err != nil {
+				t.Fatalf("gzip: %v", err)
+			}
+			var b Batch
+			if err := json.NewDecoder(gz).Decode(&b); err != nil {
+				t.Fatalf("decode batch: %v", err)
+			}
+			batches = append(batches, b)
+			results := make([]ItemResult, len(b.Items))
+			for i, item := range b.Items {
+				results[i].Status = http.StatusOK
+				if strings.Contains(string(item.Body), "reject") {
+					results[i].Status = http.StatusBadRequest
+				}
+				if strings.Contains(string(item.Body), "busy") && len(batches) == 1 {
+					results[i].Status = http.StatusTooManyRequests
+				}
+			}
+			json.NewEncoder(w).Encode(IngestResponse{Results: results})
+		}
+	}))
+	defer central.Close()
+
+	node, err := NewNode(Config{CentralURL: central.URL, Token: "relay-token", Version: "1.0.0", MaxQueue: 3})
+	if err != nil {
+		t.Fatalf("NewNode: %v", err)
+	}
+	h := node.Handler()
+	upload := func(path, auth, body string) int {
+		req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(body))
+		if auth != "" {
+			req.Header.Set("Authorization", auth)
+		}
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	if code := upload("/api/v1/devices/batch", "", `{}`); code != http.StatusUnauthorized {
+		t.Fatalf("upload without token: %d", code)
+	}
+	if code := upload("/api/v1/agents/heartbeat", "Bearer a", `{}`); code != http.StatusNotFound {
+		t.Fatalf("non-forwardable path: %d", code)
+	}
+	if code := upload("/api/v1/metrics/batch", "Bearer a", `{not json`); code != http.StatusBadRequest {
+		t.Fatalf("invalid JSON: %d", code)
+	}
+	for _, body := range []string{`{"n":"ok"}`, `{"n":"reject"}`, `{"n":"busy"}`} {
+		if code := upload("/api/v1/metrics/batch", "Bearer agent-token", body); code != http.StatusAccepted {
+			t.Fatalf("upload %s: %d", body, code)
+		}
+	}
+	if code := upload("/api/v1/devices/batch", "Bearer agent-token", `{}`); code != http.StatusServiceUnavailable {
+		t.Fatalf("upload to full queue: %d", code)
+	}
+
+	ctx := context.Background()
+	if err := node.Flush(ctx); err == nil {
+		t.Fatal("expected deferred item to end the flush with an error")
+	}
+	if node.QueueDepth() != 1 {
+		t.Fatalf("expected the deferred item to stay queued, depth %d", node.QueueDepth())
+	}
+	if err := node.Flush(ctx); err != nil {
+		t.Fatalf("second Flush: %v", err)
+	}
+	if len(batches) != 2 || len(batches[0].Items) != 3 || batches[0].Items[0].Authorization != "Bearer agent-token" ||
+		batches[0].Items[0].Path != "/api/v1/metrics/batch" || string(batches[1].Items[0].Body) != `{"n":"busy"}` {
+		t.Fatalf("unexpected batches: %+v", batches)
+	}
+
+	if err := node.Heartbeat(ctx); err != nil {
+		t.Fatalf("Heartbeat: %v", err)
+	}
+	if heartbeat.Version != "1.0.0" || heartbeat.QueueDepth != 0 || heartbeat.ForwardedTotal != 2 || heartbeat.DroppedTotal != 2 {
+		t.Fatalf("unexpected heartbeat: %+v", heartbeat)
+	}
+}
+
+func TestHealth(t *testing.T) {
+	t.Parallel()
+
+	now := time.Now()
+	recent := now.Add(-10 * time.Second)
+	stale := now.Add(-5 * time.Minute)
+	relays := []*storage.Relay{
+		{ID: 1, Name: "new"},
+		{ID: 2, Name: "ok", URL: "https://a", LastSeen: &recent},
+		{ID: 3, Name: "backlog", LastSeen: &recent, QueueDepth: DegradedQueueDepth},
+		{ID: 4, Name: "gone", LastSeen: &stale},
+	}
+	want := []string{StatusOffline, StatusHealthy, StatusDegraded, StatusOffline}
+	for i, r := range relays {
+		if got := Health(r, now); got != want[i] {
+			t.Errorf("Health(%s) = %s, want %s", r.Name, got, want[i])
+		}
+	}
+	if got := Assignments(relays, now); len(got) != 1 || got[0].ID != 2 || got[0].URL != "https://a" {
+		t.Fatalf("Assignments = %+v", got)
+	}
+}
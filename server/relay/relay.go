@@ -0,0 +1,112 @@
+// Package relay implements regional relay nodes for large fleets. A relay
+// runs close to a group of agents, accepts their upload batches and forwards
+// them to the central server in compressed bundles, so agents far from the
+// central server get fast responses and the central server sees fewer, larger
+// requests. Relays are registered on the central server, which issues their
+// tokens, tracks their health from heartbeats and hands healthy relays to
+// agents; each agent picks the one with the lowest round-trip time.
+package relay
+
+import (
+	"encoding/json"
+	"time"
+
+	"printmaster/server/storage"
+)
+
+// Paths served by relays and by the central server.
+const (
+	HealthPath    = "/relay/health"            // relay: liveness probe agents use to measure RTT
+	IngestPath    = "/api/v1/relays/ingest"    // central: compressed batches from relays
+	HeartbeatPath = "/api/v1/relays/heartbeat" // central: relay status
+)
+
+// ForwardPaths are the agent uploads a relay accepts. They are fire-and-forget
+// batches whose response the agent ignores, so the relay can answer them
+// immediately and forward later.
+var ForwardPaths = []string{
+	"/api/v1/devices/batch",
+	"/api/v1/metrics/batch",
+	"/api/v1/parse-samples/batch",
+	"/api/v1/unknown-devices/batch",
+}
+
+// Forwardable reports whether path is an agent upload a relay may forward.
+func Forwardable(path string) bool {
+	for _, p := range ForwardPaths {
+		if p == path {
+			return true
+		}
+	}
+	return false
+}
+
+// Item is one agent upload held by a relay.
+type Item struct {
+	Path          string          `json:"path"`
+	Authorization string          `json:"authorization"` // The agent's own bearer token, checked by the central server
+	RemoteIP      string          `json:"remote_ip,omitempty"`
+	ReceivedAt    time.Time       `json:"received_at"`
+	Body          json.RawMessage `json:"body"`
+}
+
+// Batch is the gzip-compressed JSON body a relay posts to IngestPath.
+type Batch struct {
+	Items []Item `json:"items"`
+}
+
+// ItemResult is the central server's outcome for one forwarded item.
+type ItemResult struct {
+	Status int    `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
+
+// IngestResponse answers a Batch, with one result per item in order.
+type IngestResponse struct {
+	Results []ItemResult `json:"results"`
+}
+
+// Health states derived from a relay's heartbeats.
+const (
+	StatusHealthy  = "healthy"
+	StatusDegraded = "degraded" // reachable but backlogged or dropping uploads
+	StatusOffline  = "offline"
+)
+
+// OfflineAfter is how long a relay may go without a heartbeat before it is
+// considered offline. Relays send one every 30 seconds.
+const OfflineAfter = 90 * time.Second
+
+// DegradedQueueDepth is the backlog at which a relay stops being offered to
+// agents.
+const DegradedQueueDepth = 1000
+
+// Health returns the health state of r at now.
+func Health(r *storage.Relay, now time.Time) string {
+	if r.LastSeen == nil || now.Sub(*r.LastSeen) > OfflineAfter {
+		return StatusOffline
+	}
+	if r.QueueDepth >= DegradedQueueDepth {
+		return StatusDegraded
+	}
+	return StatusHealthy
+}
+
+// Assignment is a relay offered to agents in heartbeat responses.
+type Assignment struct {
+	ID     int64  `json:"id"`
+	Name   string `json:"name"`
+	Region string `json:"region,omitempty"`
+	URL    string `json:"url"`
+}
+
+// Assignments returns the healthy relays agents may use.
+func Assignments(relays []*storage.Relay, now time.Time) []Assignment {
+	var out []Assignment
+	for _, r := range relays {
+		if Health(r, now) == StatusHealthy {
+			out = append(out, Assignment{ID: r.ID, Name: r.Name, Region: r.Region, URL: r.URL})
+		}
+	}
+	return out
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"printmaster/server/relay"
+)
+
+// runRelayCommand runs this binary as a regional relay:
+//
+//	printmaster-server relay --central https://pm.example.com --token <relay token>
+//
+// A relay needs no database or config file. Register it on the central
+// server first ("admin relay create" or POST /api/v1/relays) to get its token.
+func runRelayCommand(args []string, stderr io.Writer) int {
+	listenDefault := os.Getenv("RELAY_LISTEN")
+	if listenDefault == "" {
+		listenDefault = ":9443"
+	}
+	fs := flag.NewFlagSet("relay", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	central := fs.String("central", os.Getenv("RELAY_CENTRAL_URL"), "Central server base URL (env RELAY_CENTRAL_URL)")
+	token := fs.String("token", os.Getenv("RELAY_TOKEN"), "Relay token issued by the central server (env RELAY_TOKEN)")
+	listen := fs.String("listen", listenDefault, "Address agents connect to (env RELAY_LISTEN)")
+	certFile := fs.String("tls-cert", os.Getenv("RELAY_TLS_CERT"), "TLS certificate for the listener; plain HTTP when empty (env RELAY_TLS_CERT)")
+	keyFile := fs.String("tls-key", os.Getenv("RELAY_TLS_KEY"), "TLS private key for the listener (env RELAY_TLS_KEY)")
+	caFile := fs.String("ca-cert", os.Getenv("RELAY_CA_CERT"), "CA certificate for a central server with a private certificate (env RELAY_CA_CERT)")
+	insecure := fs.Bool("insecure-skip-verify", false, "Do not verify the central server's certificate")
+	maxQueue := fs.Int("max-queue", 10000, "Uploads held while the central server is unreachable")
+	flush := fs.Duration("flush-interval", 5*time.Second, "How often queued uploads are forwarded")
+	fs.Usage = func() {
+		fmt.Fprintf(stderr, "Usage: printmaster-server relay --central URL --token TOKEN [flags]\n\nFlags:\n")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if (*certFile == "") != (*keyFile == "") {
+		fmt.Fprintln(stderr, "relay: --tls-cert and --tls-key must be given together")
+		return 2
+	}
+
+	tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12, InsecureSkipVerify: *insecure}
+	if *caFile != "" {
+		pem, err := os.ReadFile(*caFile)
+		if err != nil {
+			fmt.Fprintf(stderr, "relay: read CA certificate: %v\n", err)
+			return 1
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			fmt.Fprintln(stderr, "relay: no certificates found in CA file")
+			return 1
+		}
+		tlsConfig.RootCAs = pool
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = tlsConfig
+
+	logger := slog.New(slog.NewTextHandler(stderr, nil))
+	node, err := relay.NewNode(relay.Config{
+		CentralURL:    *central,
+		Token:         *token,
+		Version:       Version,
+		FlushInterval: *flush,
+		MaxQueue:      *maxQueue,
+		Client:        &http.Client{Timeout: 60 * time.Second, Transport: transport},
+		Logger:        logger,
+	})
+	if err != nil {
+		fmt.Fprintf(stderr, "relay: %v\n\n", err)
+		fs.Usage()
+		return 2
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	srv := &http.Server{
+		Addr:              *listen,
+		Handler:           node.Handler(),
+		ReadHeaderTimeout: 10 * time.Second,
+		ReadTimeout:       2 * time.Minute,
+		WriteTimeout:      30 * time.Second,
+	}
+	done := make(chan struct{})
+	go func() {
+		node.Run(ctx)
+		close(done)
+	}()
+	go func() {
+		<-ctx.Done()
+		shutdown, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		srv.Shutdown(shutdown)
+	}()
+
+	logger.Info("Relay started", "listen", *listen, "central", *central, "tls", *certFile != "", "version", Version)
+	if *certFile != "" {
+		err = srv.ListenAndServeTLS(*certFile, *keyFile)
+	} else {
+		err = srv.ListenAndServe()
+	}
+	if err != nil && !errors.Is(err, http.ErrServerClosed) {
+		logger.Error("Relay listener failed", "error", err)
+		stop()
+		<-done
+		return 1
+	}
+	<-done
+	logger.Info("Relay stopped", "queued", node.QueueDepth())
+	return 0
+}
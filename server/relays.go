@@ -0,0 +1,371 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	authz "printmaster/server/authz"
+	"printmaster/server/relay"
+	"printmaster/server/storage"
+)
+
+const relayContextKey contextKey = "relay"
+
+// maxRelayBatchBytes caps a decompressed relay batch.
+const maxRelayBatchBytes = 128 << 20
+
+// relayForwardHandler serves uploads replayed from relay batches; nil means
+// the default mux, where the agent upload routes are registered.
+var relayForwardHandler http.Handler
+
+// relayView is a relay as shown to admins, with its derived health.
+type relayView struct {
+	*storage.Relay
+	Health string `json:"health"`
+}
+
+// handleRelays lists or registers relays.
+// GET/POST /api/v1/relays
+func handleRelays(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		if !authorizeOrReject(w, r, authz.ActionSettingsServerRead, authz.ResourceRef{}) {
+			return
+		}
+		relays, err := serverStore.ListRelays(r.Context())
+		if err != nil {
+			logError("Failed to list relays", "error", err)
+			http.Error(w, "failed to list relays", http.StatusInternalServerError)
+			return
+		}
+		now := time.Now()
+		views := make([]relayView, 0, len(relays))
+		for _, rl := range relays {
+			views = append(views, relayView{Relay: rl, Health: relay.Health(rl, now)})
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(views)
+	case http.MethodPost:
+		if !authorizeOrReject(w, r, authz.ActionSettingsServerWrite, authz.ResourceRef{}) {
+			return
+		}
+		var req struct {
+			Name   string `json:"name"`
+			Region string `json:"region"`
+			URL    string `json:"url"`
+		}
+		if err := decodeJSONBody(r, &req); err != nil {
+			http.Error(w, "invalid JSON", http.StatusBadRequest)
+			return
+		}
+		_, _, actorName, _ := auditActorFromPrincipal(r)
+		rl := &storage.Relay{Name: req.Name, Region: req.Region, URL: req.URL, CreatedBy: actorName}
+		if err := rl.Validate(); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		existing, err := serverStore.ListRelays(r.Context())
+		if err != nil {
+			logError("Failed to list relays", "error", err)
+			http.Error(w, "failed to register relay", http.StatusInternalServerError)
+			return
+		}
+		for _, e := range existing {
+			if strings.EqualFold(e.Name, rl.Name) {
+				http.Error(w, "a relay with this name already exists", http.StatusConflict)
+				return
+			}
+		}
+		token, err := serverStore.CreateRelay(r.Context(), rl)
+		if err != nil {
+			logError("Failed to register relay", "name", rl.Name, "error", err)
+			http.Error(w, "failed to register relay", http.StatusInternalServerError)
+			return
+		}
+		invalidateRelayAssignments()
+		auditRelay(r, "relay.create", rl, fmt.Sprintf("region=%s url=%s", rl.Region, rl.URL))
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"relay": relayView{Relay: rl, Health: relay.StatusOffline},
+			"token": token,
+		})
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// handleRelay removes a relay; its token stops working and its agents move
+// back to uploading directly.
+// DELETE /api/v1/relays/{id}
+func handleRelay(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(strings.TrimPrefix(r.URL.Path, "/api/v1/relays/"), 10, 64)
+	if err != nil || id <= 0 {
+		http.NotFound(w, r)
+		return
+	}
+	if r.Method != http.MethodDelete {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !authorizeOrReject(w, r, authz.ActionSettingsServerWrite, authz.ResourceRef{}) {
+		return
+	}
+	rl, err := serverStore.GetRelay(r.Context(), id)
+	if err != nil {
+		logError("Failed to load relay", "id", id, "error", err)
+		http.Error(w, "failed to load relay", http.StatusInternalServerError)
+		return
+	}
+	if rl == nil {
+		http.Error(w, "relay not found", http.StatusNotFound)
+		return
+	}
+	if err := serverStore.DeleteRelay(r.Context(), id); err != nil {
+		logError("Failed to delete relay", "id", id, "error", err)
+		http.Error(w, "failed to delete relay", http.StatusInternalServerError)
+		return
+	}
+	invalidateRelayAssignments()
+	auditRelay(r, "relay.delete", rl, "")
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func auditRelay(r *http.Request, action string, rl *storage.Relay, details string) {
+	actorType, actorID, actorName, actorTenant := auditActorFromPrincipal(r)
+	logInfo("Relay change", "action", action, "id", rl.ID, "name", rl.Name, "actor", actorName)
+	logAuditEntry(r.Context(), &storage.AuditEntry{
+		ActorType:  actorType,
+		ActorID:    actorID,
+		ActorName:  actorName,
+		TenantID:   actorTenant,
+		Action:     action,
+		TargetType: "relay",
+		TargetID:   strconv.FormatInt(rl.ID, 10),
+		Details:    details,
+		IPAddress:  extractClientIP(r),
+		UserAgent:  r.Header.Get("User-Agent"),
+	})
+}
+
+// requireRelayAuth authenticates a relay by its bearer token.
+func requireRelayAuth(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		clientIP := getRealIP(r)
+		token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if token == "" || token == r.Header.Get("Authorization") {
+			http.Error(w, "Missing Authorization header", http.StatusUnauthorized)
+			return
+		}
+		tokenPrefix := token
+		if len(token) > 8 {
+			tokenPrefix = token[:8]
+		}
+		if authRateLimiter != nil {
+			if blocked, _ := authRateLimiter.IsBlocked(clientIP, tokenPrefix); blocked {
+				http.Error(w, "Too many failed attempts. Try again later.", http.StatusTooManyRequests)
+				return
+			}
+		}
+		rl, err := serverStore.GetRelayByToken(r.Context(), token)
+		if err != nil {
+			logError("Relay authentication failed", "error", err)
+			http.Error(w, "internal error", http.StatusInternalServerError)
+			return
+		}
+		if rl == nil {
+			if authRateLimiter != nil {
+				authRateLimiter.RecordFailure(clientIP, tokenPrefix)
+			}
+			logWarn("Invalid relay token", "ip", clientIP, "token", tokenPrefix+"...")
+			http.Error(w, "Invalid token", http.StatusUnauthorized)
+			return
+		}
+		if authRateLimiter != nil {
+			authRateLimiter.RecordSuccess(clientIP, tokenPrefix)
+		}
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), relayContextKey, rl)))
+	}
+}
+
+func relayFromContext(r *http.Request) *storage.Relay {
+	rl, _ := r.Context().Value(relayContextKey).(*storage.Relay)
+	return rl
+}
+
+// handleRelayHeartbeat records a relay's status.
+// POST /api/v1/relays/heartbeat
+func handleRelayHeartbeat(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "POST only", http.StatusMethodNotAllowed)
+		return
+	}
+	rl := relayFromContext(r)
+	var hb storage.RelayHeartbeat
+	if err := decodeJSONBody(r, &hb); err != nil {
+		http.Error(w, "invalid JSON", http.StatusBadRequest)
+		return
+	}
+	wasHealthy := relay.Health(rl, time.Now()) == relay.StatusHealthy
+	if err := serverStore.UpdateRelayHeartbeat(r.Context(), rl.ID, hb, time.Now()); err != nil {
+		logError("Failed to record relay heartbeat", "relay", rl.Name, "error", err)
+		http.Error(w, "failed to record heartbeat", http.StatusInternalServerError)
+		return
+	}
+	if !wasHealthy {
+		logInfo("Relay online", "relay", rl.Name, "region", rl.Region, "version", hb.Version, "queue_depth", hb.QueueDepth)
+		invalidateRelayAssignments()
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"success": true})
+}
+
+// handleRelayIngest replays a relay's batch of agent uploads through the
+// regular upload handlers, each with the agent's own token, and reports the
+// outcome of every item.
+// POST /api/v1/relays/ingest
+func handleRelayIngest(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "POST only", http.StatusMethodNotAllowed)
+		return
+	}
+	rl := relayFromContext(r)
+	var body io.Reader = r.Body
+	if strings.EqualFold(r.Header.Get("Content-Encoding"), "gzip") {
+		gz, err := gzip.NewReader(r.Body)
+		if err != nil {
+			http.Error(w, "invalid gzip body", http.StatusBadRequest)
+			return
+		}
+		defer gz.Close()
+		body = gz
+	}
+	var batch relay.Batch
+	if err := json.NewDecoder(io.LimitReader(body, maxRelayBatchBytes)).Decode(&batch); err != nil {
+		http.Error(w, "invalid batch", http.StatusBadRequest)
+		return
+	}
+
+	handler := relayForwardHandler
+	if handler == nil {
+		handler = http.DefaultServeMux
+	}
+	results := make([]relay.ItemResult, len(batch.Items))
+	for i, item := range batch.Items {
+		results[i] = replayRelayItem(r.Context(), handler, rl, item)
+	}
+	logDebug("Relay batch ingested", "relay", rl.Name, "items", len(batch.Items))
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(relay.IngestResponse{Results: results})
+}
+
+func replayRelayItem(ctx context.Context, handler http.Handler, rl *storage.Relay, item relay.Item) relay.ItemResult {
+	if !relay.Forwardable(item.Path) {
+		return relay.ItemResult{Status: http.StatusBadRequest, Error: "path not accepted from relays"}
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, item.Path, bytes.NewReader(item.Body))
+	if err != nil {
+		return relay.ItemResult{Status: http.StatusBadRequest, Error: err.Error()}
+	}
+	req.Header.Set("Authorization", item.Authorization)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "PrintMaster-Relay/"+rl.Name)
+	if ip := net.ParseIP(item.RemoteIP); ip != nil {
+		req.RemoteAddr = net.JoinHostPort(ip.String(), "0")
+	}
+	rec := &relayResponseRecorder{header: http.Header{}}
+	handler.ServeHTTP(rec, req)
+	res := relay.ItemResult{Status: rec.status()}
+	if res.Status >= 300 {
+		res.Error = strings.TrimSpace(rec.body.String())
+	}
+	return res
+}
+
+// relayResponseRecorder captures a replayed upload's response.
+type relayResponseRecorder struct {
+	header http.Header
+	code   int
+	body   bytes.Buffer
+}
+
+func (r *relayResponseRecorder) Header() http.Header { return r.header }
+
+func (r *relayResponseRecorder) WriteHeader(code int) {
+	if r.code == 0 {
+		r.code = code
+	}
+}
+
+func (r *relayResponseRecorder) Write(b []byte) (int, error) {
+	if r.code == 0 {
+		r.code = http.StatusOK
+	}
+	if r.body.Len() < 512 {
+		r.body.Write(b)
+	}
+	return len(b), nil
+}
+
+func (r *relayResponseRecorder) status() int {
+	if r.code == 0 {
+		return http.StatusOK
+	}
+	return r.code
+}
+
+// Healthy relays handed to agents in heartbeat responses. Cached briefly so
+// heartbeats from a large fleet don't each list the relays.
+var relayAssignments struct {
+	sync.Mutex
+	list    []relay.Assignment
+	fetched time.Time
+}
+
+const relayAssignmentsTTL = 15 * time.Second
+
+func currentRelayAssignments(ctx context.Context) []relay.Assignment {
+	relayAssignments.Lock()
+	defer relayAssignments.Unlock()
+	if time.Since(relayAssignments.fetched) < relayAssignmentsTTL {
+		return relayAssignments.list
+	}
+	relays, err := serverStore.ListRelays(ctx)
+	if err != nil {
+		logWarn("Failed to list relays for agent assignment", "error", err)
+		return relayAssignments.list
+	}
+	relayAssignments.list = relay.Assignments(relays, time.Now())
+	relayAssignments.fetched = time.Now()
+	return relayAssignments.list
+}
+
+func invalidateRelayAssignments() {
+	relayAssignments.Lock()
+	relayAssignments.fetched = time.Time{}
+	relayAssignments.Unlock()
+}
+
+// recordAgentRelay stores the relay an agent reported using, ignoring relays
+// that no longer exist.
+func recordAgentRelay(ctx context.Context, agentID string, relayID int64, rttMS int) {
+	if relayID != 0 {
+		rl, err := serverStore.GetRelay(ctx, relayID)
+		if err != nil || rl == nil {
+			relayID = 0
+		}
+	}
+	if err := serverStore.SetAgentRelay(ctx, agentID, relayID, rttMS); err != nil {
+		logWarn("Failed to record agent relay", "agent_id", agentID, "relay_id", relayID, "error", err)
+	}
+}
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"printmaster/server/relay"
+	"printmaster/server/storage"
+)
+
+func TestRelayRegistrationHeartbeatAndIngest(t *testing.T) {
+	store := SetupTestStore(t)
+	ctx := context.Background()
+	invalidateRelayAssignments()
+	t.Cleanup(invalidateRelayAssignments)
+
+	// Register a relay
+	body := `{"name":"eu-west","region":"Europe","url":"https://relay-eu.example.com"}`
+	rr := httptest.NewRecorder()
+	handleRelays(rr, InjectTestAdmin(httptest.NewRequest(http.MethodPost, "/api/v1/relays", strings.NewReader(body))))
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("create relay: %d %s", rr.Code, rr.Body.String())
+	}
+	var created struct {
+		Relay relayView `json:"relay"`
+		Token string    `json:"token"`
+	}
+	json.Unmarshal(rr.Body.Bytes(), &created)
+	if created.Token == "" || created.Relay.ID == 0 || created.Relay.Health != relay.StatusOffline {
+		t.Fatalf("unexpected create response: %s", rr.Body.String())
+	}
+	rr = httptest.NewRecorder()
+	handleRelays(rr, InjectTestAdmin(httptest.NewRequest(http.MethodPost, "/api/v1/relays", strings.NewReader(body))))
+	if rr.Code != http.StatusConflict {
+		t.Fatalf("duplicate relay name: expected 409, got %d", rr.Code)
+	}
+	viewer := NewTestUser(storage.RoleViewer)
+	rr = httptest.NewRecorder()
+	handleRelays(rr, InjectTestUser(httptest.NewRequest(http.MethodPost, "/api/v1/relays", strings.NewReader(`{"name":"x","url":"https://x"}`)), viewer))
+	if rr.Code != http.StatusForbidden {
+		t.Fatalf("viewer registering a relay: expected 403, got %d", rr.Code)
+	}
+
+	relayRequest := func(handler http.HandlerFunc, path, token string, payload []byte, gzipped bool) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, path, bytes.NewReader(payload))
+		req.Header.Set("Authorization", "Bearer "+token)
+		if gzipped {
+			req.Header.Set("Content-Encoding", "gzip")
+		}
+		rec := httptest.NewRecorder()
+		requireRelayAuth(handler)(rec, req)
+		return rec
+	}
+
+	// Heartbeats make the relay healthy and offered to agents
+	if rec := relayRequest(handleRelayHeartbeat, relay.HeartbeatPath, "wrong", []byte(`{}`), false); rec.Code != http.StatusUnauthorized {
+		t.Fatalf("heartbeat with a bad token: expected 401, got %d", rec.Code)
+	}
+	if rec := relayRequest(handleRelayHeartbeat, relay.HeartbeatPath, created.Token, []byte(`{"version":"1.0.0","queue_depth":2}`), false); rec.Code != http.StatusOK {
+		t.Fatalf("heartbeat: %d %s", rec.Code, rec.Body.String())
+	}
+
+	agent := &storage.Agent{AgentID: "agent-a", Hostname: "a", Token: "agent-token", Status: "active", RegisteredAt: time.Now(), LastSeen: time.Now()}
+	if err := store.RegisterAgent(ctx, agent); err != nil {
+		t.Fatalf("RegisterAgent: %v", err)
+	}
+	raw, _ := json.Marshal(map[string]interface{}{"agent_id": agent.AgentID, "status": "active", "relay_id": created.Relay.ID, "relay_rtt_ms": 18})
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/agents/heartbeat", bytes.NewReader(raw))
+	req = req.WithContext(context.WithValue(req.Context(), agentContextKey, agent))
+	rr = httptest.NewRecorder()
+	handleAgentHeartbeat(rr, req)
+	var hbResp struct {
+		Relays []relay.Assignment `json:"relays"`
+	}
+	json.Unmarshal(rr.Body.Bytes(), &hbResp)
+	if len(hbResp.Relays) != 1 || hbResp.Relays[0].URL != "https://relay-eu.example.com" {
+		t.Fatalf("agent heartbeat did not offer the relay: %s", rr.Body.String())
+	}
+	if id, _ := store.GetAgentRelayID(ctx, agent.AgentID); id != created.Relay.ID {
+		t.Fatalf("agent relay not recorded: %d", id)
+	}
+
+	// Ingest replays allowed uploads with the agent's token and address
+	var replayed []*http.Request
+	stub := http.NewServeMux()
+	stub.HandleFunc("/api/v1/metrics/batch", func(w http.ResponseWriter, r *http.Request) {
+		replayed = append(replayed, r)
+		if r.Header.Get("Authorization") != "Bearer agent-token" {
+			http.Error(w, "Invalid token", http.StatusUnauthorized)
+			return
+		}
+		w.Write([]byte(`{"success":true}`))
+	})
+	relayForwardHandler = stub
+	t.Cleanup(func() { relayForwardHandler = nil })
+
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	json.NewEncoder(gz).Encode(relay.Batch{Items: []relay.Item{
+		{Path: "/api/v1/metrics/batch", Authorization: "Bearer agent-token", RemoteIP: "10.1.2.3", Body: json.RawMessage(`{"metrics":[]}`)},
+		{Path: "/api/v1/metrics/batch", Authorization: "Bearer stolen", Body: json.RawMessage(`{}`)},
+		{Path: "/api/v1/agents/heartbeat", Authorization: "Bearer agent-token", Body: json.RawMessage(`{}`)},
+	}})
+	gz.Close()
+	rec := relayRequest(handleRelayIngest, relay.IngestPath, created.Token, buf.Bytes(), true)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("ingest: %d %s", rec.Code, rec.Body.String())
+	}
+	var ingest relay.IngestResponse
+	json.Unmarshal(rec.Body.Bytes(), &ingest)
+	if len(ingest.Results) != 3 || ingest.Results[0].Status != http.StatusOK ||
+		ingest.Results[1].Status != http.StatusUnauthorized || ingest.Results[2].Status != http.StatusBadRequest {
+		t.Fatalf("unexpected ingest results: %+v", ingest.Results)
+	}
+	if len(replayed) != 2 {
+		t.Fatalf("expected 2 replayed uploads, got %d", len(replayed))
+	}
+	if host, _, _ := net.SplitHostPort(replayed[0].RemoteAddr); host != "10.1.2.3" {
+		t.Fatalf("replayed upload has remote address %q", replayed[0].RemoteAddr)
+	}
+
+	// Listing shows health and assigned agents; deleting revokes the token
+	rr = httptest.NewRecorder()
+	handleRelays(rr, InjectTestAdmin(httptest.NewRequest(http.MethodGet, "/api/v1/relays", nil)))
+	var views []relayView
+	json.Unmarshal(rr.Body.Bytes(), &views)
+	if len(views) != 1 || views[0].Health != relay.StatusHealthy || views[0].AgentCount != 1 || views[0].Version != "1.0.0" {
+		t.Fatalf("unexpected relay list: %s", rr.Body.String())
+	}
+	rr = httptest.NewRecorder()
+	handleRelay(rr, InjectTestAdmin(httptest.NewRequest(http.MethodDelete, "/api/v1/relays/"+strconv.FormatInt(created.Relay.ID, 10), nil)))
+	if rr.Code != http.StatusNoContent {
+		t.Fatalf("delete relay: %d", rr.Code)
+	}
+	if rec := relayRequest(handleRelayHeartbeat, relay.HeartbeatPath, created.Token, []byte(`{}`), false); rec.Code != http.StatusUnauthorized {
+		t.Fatalf("heartbeat after delete: expected 401, got %d", rec.Code)
+	}
+}
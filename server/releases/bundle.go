@@ -0,0 +1,468 @@
+package releases
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bufio"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"printmaster/server/storage"
+)
+
+// BundleResult summarizes an offline release bundle import.
+type BundleResult struct {
+	Source   string           `json:"source"`
+	Imported []BundleArtifact `json:"imported"`
+	Skipped  []BundleSkip     `json:"skipped,omitempty"`
+}
+
+// BundleArtifact describes one artifact cached from a bundle.
+type BundleArtifact struct {
+	Component string `json:"component"`
+	Version   string `json:"version"`
+	Platform  string `json:"platform"`
+	Arch      string `json:"arch"`
+	FileName  string `json:"file_name"`
+	SHA256    string `json:"sha256"`
+	SizeBytes int64  `json:"size_bytes"`
+	Verified  bool   `json:"verified"` // Matched an entry in the bundle's checksum file
+}
+
+// BundleSkip records a bundle file that was not imported and why.
+type BundleSkip struct {
+	FileName string `json:"file_name"`
+	Reason   string `json:"reason"`
+}
+
+// checksumFileNames are recognised as sha256sum-style manifests inside a bundle.
+var checksumFileNames = map[string]bool{
+	"sha256sums":     true,
+	"sha256sums.txt": true,
+	"checksums.txt":  true,
+}
+
+// IsBundleArchive reports whether the file name has a supported bundle
+// archive extension (.zip, .tar.gz or .tgz).
+func IsBundleArchive(name string) bool {
+	lower := strings.ToLower(name)
+	return strings.HasSuffix(lower, ".zip") || strings.HasSuffix(lower, ".tar.gz") || strings.HasSuffix(lower, ".tgz")
+}
+
+// ImportBundle caches release artifacts from an offline bundle so they can be
+// served to agents without internet access. The source may be a directory
+// (for example a mounted USB drive) or a .zip/.tar.gz archive. Files must use
+// the GitHub release asset names; an optional SHA256SUMS or checksums.txt is
+// used to verify them, and files that fail verification are rejected.
+func (w *IntakeWorker) ImportBundle(ctx context.Context, source string) (*BundleResult, error) {
+	info, err := os.Stat(source)
+	if err != nil {
+		return nil, err
+	}
+	staging, err := os.MkdirTemp(w.cacheDir, "bundle-*")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create staging dir: %w", err)
+	}
+	defer os.RemoveAll(staging)
+
+	files := map[string]string{}
+	switch {
+	case info.IsDir():
+		err = filepath.WalkDir(source, func(p string, d fs.DirEntry, walkErr error) error {
+			if walkErr != nil {
+				return walkErr
+			}
+			if !d.Type().IsRegular() {
+				return nil
+			}
+			if IsBundleArchive(d.Name()) {
+				dir, err := os.MkdirTemp(staging, "archive-*")
+				if err != nil {
+					return err
+				}
+				if err := extractBundle(p, dir); err != nil {
+					return fmt.Errorf("%s: %w", d.Name(), err)
+				}
+				return collectBundleFiles(dir, files)
+			}
+			if isBundleMember(d.Name()) {
+				files[d.Name()] = p
+			}
+			return nil
+		})
+	case IsBundleArchive(source):
+		if err = extractBundle(source, staging); err == nil {
+			err = collectBundleFiles(staging, files)
+		}
+	default:
+		err = fmt.Errorf("unsupported bundle %q: expected a directory, .zip, .tar.gz or .tgz", filepath.Base(source))
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return w.importBundleFiles(ctx, filepath.Base(source), files)
+}
+
+func (w *IntakeWorker) importBundleFiles(ctx context.Context, source string, files map[string]string) (*BundleResult, error) {
+	result := &BundleResult{Source: source, Imported: []BundleArtifact{}}
+
+	checksums := map[string]string{}
+	for name, p := range files {
+		if !checksumFileNames[strings.ToLower(name)] {
+			continue
+		}
+		if err := readChecksums(p, checksums); err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", name, err)
+		}
+	}
+
+	// Several assets can map to the same component/version/platform/arch
+	// (e.g. .exe and .msi). Only one is cached per tuple, so prefer the plain
+	// binary that agents and the server use for self-update.
+	chosen := map[string]artifactDescriptor{}
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if checksumFileNames[strings.ToLower(name)] {
+			continue
+		}
+		desc, ok := parseAssetName(name)
+		if !ok {
+			result.Skipped = append(result.Skipped, BundleSkip{FileName: name, Reason: "unrecognised asset name"})
+			continue
+		}
+		key := strings.Join([]string{desc.component, desc.version, desc.platform, desc.arch}, "/")
+		if prev, ok := chosen[key]; ok {
+			if isPackageAsset(prev.fileName) && !isPackageAsset(name) {
+				result.Skipped = append(result.Skipped, BundleSkip{FileName: prev.fileName, Reason: "superseded by " + name})
+				chosen[key] = desc
+			} else {
+				result.Skipped = append(result.Skipped, BundleSkip{FileName: name, Reason: "superseded by " + prev.fileName})
+			}
+			continue
+		}
+		chosen[key] = desc
+	}
+
+	keys := make([]string, 0, len(chosen))
+	for key := range chosen {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		if err := ctx.Err(); err != nil {
+			return result, err
+		}
+		desc := chosen[key]
+		want := checksums[desc.fileName]
+		artifact, err := w.importBundleArtifact(ctx, desc, files[desc.fileName], want, source)
+		if err != nil {
+			w.logWarn("bundle artifact rejected", "file", desc.fileName, "error", err)
+			result.Skipped = append(result.Skipped, BundleSkip{FileName: desc.fileName, Reason: err.Error()})
+			continue
+		}
+		result.Imported = append(result.Imported, BundleArtifact{
+			Component: artifact.Component,
+			Version:   artifact.Version,
+			Platform:  artifact.Platform,
+			Arch:      artifact.Arch,
+			FileName:  desc.fileName,
+			SHA256:    artifact.SHA256,
+			SizeBytes: artifact.SizeBytes,
+			Verified:  want != "",
+		})
+	}
+
+	w.logInfo("release bundle imported", "source", source, "imported", len(result.Imported), "skipped", len(result.Skipped))
+	return result, nil
+}
+
+func (w *IntakeWorker) importBundleArtifact(ctx context.Context, desc artifactDescriptor, srcPath, wantSHA, source string) (*storage.ReleaseArtifact, error) {
+	componentDir, err := buildCacheDir(w.cacheDir, desc)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(componentDir, 0o755); err != nil {
+		return nil, err
+	}
+
+	src, err := os.Open(srcPath)
+	if err != nil {
+		return nil, err
+	}
+	defer src.Close()
+
+	tempFile, err := os.CreateTemp(componentDir, "import-*.tmp")
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		tempFile.Close()
+		os.Remove(tempFile.Name())
+	}()
+
+	hasher := sha256.New()
+	written, err := io.Copy(io.MultiWriter(tempFile, hasher), src)
+	if err != nil {
+		return nil, err
+	}
+	checksum := hex.EncodeToString(hasher.Sum(nil))
+	if wantSHA != "" && !strings.EqualFold(wantSHA, checksum) {
+		return nil, fmt.Errorf("checksum mismatch: expected %s, got %s", wantSHA, checksum)
+	}
+	if err := tempFile.Sync(); err != nil {
+		return nil, err
+	}
+	if err := tempFile.Close(); err != nil {
+		return nil, err
+	}
+
+	finalPath := filepath.Join(componentDir, desc.fileName)
+	_ = os.Remove(finalPath)
+	if err := os.Rename(tempFile.Name(), finalPath); err != nil {
+		return nil, err
+	}
+
+	record := &storage.ReleaseArtifact{
+		Component:    desc.component,
+		Version:      desc.version,
+		Platform:     desc.platform,
+		Arch:         desc.arch,
+		Channel:      channelFromVersion(desc.version),
+		SourceURL:    "bundle:" + source,
+		CachePath:    finalPath,
+		SHA256:       checksum,
+		SizeBytes:    written,
+		PublishedAt:  time.Now().UTC(),
+		DownloadedAt: time.Now().UTC(),
+	}
+	existing, err := w.store.GetReleaseArtifact(ctx, desc.component, desc.version, desc.platform, desc.arch)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return nil, err
+	}
+	if err == nil && existing != nil {
+		// Keep metadata learned from an earlier online sync.
+		record.ReleaseNotes = existing.ReleaseNotes
+		if !existing.PublishedAt.IsZero() {
+			record.PublishedAt = existing.PublishedAt
+		}
+		if existing.CachePath != "" && existing.CachePath != finalPath {
+			_ = os.Remove(existing.CachePath)
+		}
+	}
+	if err := w.store.UpsertReleaseArtifact(ctx, record); err != nil {
+		return nil, err
+	}
+	w.logInfo("cached release artifact from bundle", "component", desc.component, "version", desc.version, "platform", desc.platform, "arch", desc.arch)
+	w.ensureManifest(ctx, record)
+	return record, nil
+}
+
+// importDirectory imports the configured offline import directory, reporting
+// progress in the same shape as an online sync.
+func (w *IntakeWorker) importDirectory(ctx context.Context, report ProgressCallback) error {
+	if report == nil {
+		report = func(SyncProgress) {}
+	}
+	if w.importDir == "" {
+		err := errors.New("offline mode: no import directory configured; upload a release bundle instead")
+		report(SyncProgress{Phase: "error", Message: "Nothing to import", Error: err.Error()})
+		return err
+	}
+	report(SyncProgress{Phase: "processing", Message: fmt.Sprintf("Importing release bundles from %s...", w.importDir)})
+	result, err := w.ImportBundle(ctx, w.importDir)
+	if err != nil {
+		report(SyncProgress{Phase: "error", Message: "Bundle import failed", Error: err.Error()})
+		return err
+	}
+	w.pruneIfConfigured(ctx)
+	report(SyncProgress{
+		Phase:           "complete",
+		Message:         fmt.Sprintf("Import complete: %d artifacts imported, %d skipped", len(result.Imported), len(result.Skipped)),
+		TotalFiles:      len(result.Imported),
+		CompletedFiles:  len(result.Imported),
+		PercentComplete: 100,
+	})
+	return nil
+}
+
+// parseAssetName recovers the component and version from a release asset
+// name, accepting the same patterns as buildDescriptor.
+func parseAssetName(name string) (artifactDescriptor, bool) {
+	rest, ok := strings.CutPrefix(name, "printmaster-")
+	if !ok {
+		return artifactDescriptor{}, false
+	}
+	for _, component := range []string{"agent", "server"} {
+		tail, ok := strings.CutPrefix(rest, component)
+		if !ok || tail == "" {
+			continue
+		}
+		var version string
+		switch {
+		case strings.HasPrefix(tail, "-v"):
+			// printmaster-agent-v0.29.1-dev.3-linux-amd64
+			v := tail[2:]
+			idx := -1
+			for _, marker := range []string{"-windows-", "-linux-", "-darwin-"} {
+				if i := strings.LastIndex(v, marker); i > idx {
+					idx = i
+				}
+			}
+			if idx > 0 {
+				version = v[:idx]
+			}
+		case strings.HasPrefix(tail, "_"):
+			// printmaster-agent_0.29.1_amd64.deb
+			if parts := strings.Split(tail[1:], "_"); len(parts) == 2 {
+				version = parts[0]
+			}
+		case strings.HasPrefix(tail, "-"):
+			// printmaster-agent-0.29.1-1.fc43.x86_64.rpm
+			v := tail[1:]
+			if i := strings.LastIndex(v, "-"); i > 0 {
+				version = v[:i]
+			}
+		}
+		if version == "" {
+			return artifactDescriptor{}, false
+		}
+		return buildDescriptor(component, version, name)
+	}
+	return artifactDescriptor{}, false
+}
+
+func isPackageAsset(name string) bool {
+	switch strings.ToLower(path.Ext(name)) {
+	case ".msi", ".deb", ".rpm":
+		return true
+	}
+	return false
+}
+
+func isBundleMember(name string) bool {
+	return strings.HasPrefix(name, "printmaster-") || checksumFileNames[strings.ToLower(name)]
+}
+
+// readChecksums parses sha256sum output ("<hex>  <name>" or "<hex> *<name>").
+func readChecksums(p string, into map[string]string) error {
+	f, err := os.Open(p)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) != 2 || len(fields[0]) != sha256.Size*2 {
+			continue
+		}
+		name := path.Base(strings.TrimPrefix(fields[1], "*"))
+		into[name] = strings.ToLower(fields[0])
+	}
+	return scanner.Err()
+}
+
+func collectBundleFiles(dir string, files map[string]string) error {
+	return filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.Type().IsRegular() && isBundleMember(d.Name()) {
+			files[d.Name()] = p
+		}
+		return nil
+	})
+}
+
+// extractBundle unpacks the recognised members of an archive into dest.
+// Directory structure is flattened, which also keeps entries from escaping dest.
+func extractBundle(archive, dest string) error {
+	if strings.HasSuffix(strings.ToLower(archive), ".zip") {
+		return extractZip(archive, dest)
+	}
+	return extractTarGz(archive, dest)
+}
+
+func extractZip(archive, dest string) error {
+	r, err := zip.OpenReader(archive)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+	for _, f := range r.File {
+		name := path.Base(f.Name)
+		if !f.Mode().IsRegular() || !isBundleMember(name) {
+			continue
+		}
+		rc, err := f.Open()
+		if err != nil {
+			return err
+		}
+		err = writeBundleMember(filepath.Join(dest, name), rc)
+		rc.Close()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func extractTarGz(archive, dest string) error {
+	f, err := os.Open(archive)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		return err
+	}
+	defer gz.Close()
+	tr := tar.NewReader(gz)
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		name := path.Base(hdr.Name)
+		if hdr.Typeflag != tar.TypeReg || !isBundleMember(name) {
+			continue
+		}
+		if err := writeBundleMember(filepath.Join(dest, name), tr); err != nil {
+			return err
+		}
+	}
+}
+
+func writeBundleMember(dst string, r io.Reader) error {
+	out, err := os.OpenFile(dst, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0o644)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, r); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
@@ -0,0 +1,191 @@
+package releases
+
+import (
+	"archive/zip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"printmaster/server/storage"
+)
+
+func TestParseAssetName(t *testing.T) {
+	t.Parallel()
+	cases := map[string]string{
+		"printmaster-agent-v0.29.1-linux-amd64":              "agent 0.29.1 linux amd64",
+		"printmaster-server-v0.30.0-dev.3-windows-amd64.exe": "server 0.30.0-dev.3 windows amd64",
+		"printmaster-agent_0.29.1_arm64.deb":                 "agent 0.29.1 linux arm64",
+		"printmaster-agent-0.29.1-1.fc43.x86_64.rpm":         "agent 0.29.1 linux amd64",
+		"printmaster-agent-v0.29.1.tar.gz":                   "",
+		"README.md":                                          "",
+	}
+	for name, want := range cases {
+		got := ""
+		if desc, ok := parseAssetName(name); ok {
+			got = fmt.Sprintf("%s %s %s %s", desc.component, desc.version, desc.platform, desc.arch)
+		}
+		if got != want {
+			t.Errorf("parseAssetName(%q) = %q, want %q", name, got, want)
+		}
+	}
+}
+
+func newBundleWorker(t *testing.T, opts Options) (*IntakeWorker, storage.Store) {
+	t.Helper()
+	store, err := storage.NewSQLiteStore(":memory:")
+	if err != nil {
+		t.Fatalf("failed to init store: %v", err)
+	}
+	t.Cleanup(func() { _ = store.Close() })
+	opts.CacheDir = t.TempDir()
+	worker, err := NewIntakeWorker(store, nil, opts)
+	if err != nil {
+		t.Fatalf("failed to create worker: %v", err)
+	}
+	return worker, store
+}
+
+func sha256Hex(data string) string {
+	sum := sha256.Sum256([]byte(data))
+	return hex.EncodeToString(sum[:])
+}
+
+func TestImportBundleZip(t *testing.T) {
+	t.Parallel()
+	worker, store := newBundleWorker(t, Options{})
+
+	files := map[string]string{
+		"printmaster-agent-v1.2.0-windows-amd64.exe": "agent-exe",
+		"printmaster-agent-v1.2.0-windows-amd64.msi": "agent-msi",
+		"printmaster-agent-v1.2.0-linux-amd64":       "agent-linux",
+		"printmaster-server-v1.2.0-linux-amd64":      "server-linux",
+	}
+	sums := fmt.Sprintf("%s  printmaster-agent-v1.2.0-windows-amd64.exe\n%s *dist/printmaster-server-v1.2.0-linux-amd64\n",
+		sha256Hex("agent-exe"), sha256Hex("tampered"))
+
+	archive := filepath.Join(t.TempDir(), "printmaster-1.2.0-offline.zip")
+	f, err := os.Create(archive)
+	if err != nil {
+		t.Fatal(err)
+	}
+	zw := zip.NewWriter(f)
+	for name, body := range files {
+		w, _ := zw.Create("dist/" + name)
+		_, _ = w.Write([]byte(body))
+	}
+	w, _ := zw.Create("SHA256SUMS")
+	_, _ = w.Write([]byte(sums))
+	w, _ = zw.Create("../../escape/printmaster-agent-v1.2.0-darwin-arm64")
+	_, _ = w.Write([]byte("agent-darwin"))
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+
+	result, err := worker.ImportBundle(context.Background(), archive)
+	if err != nil {
+		t.Fatalf("ImportBundle: %v", err)
+	}
+	if len(result.Imported) != 3 {
+		t.Fatalf("expected 3 imported artifacts, got %+v", result)
+	}
+	verified := map[string]bool{}
+	for _, a := range result.Imported {
+		verified[a.FileName] = a.Verified
+	}
+	if !verified["printmaster-agent-v1.2.0-windows-amd64.exe"] || verified["printmaster-agent-v1.2.0-linux-amd64"] {
+		t.Fatalf("unexpected verification flags: %+v", verified)
+	}
+	if _, ok := verified["printmaster-server-v1.2.0-linux-amd64"]; ok {
+		t.Fatal("artifact with checksum mismatch should be rejected")
+	}
+
+	art, err := store.GetReleaseArtifact(context.Background(), "agent", "1.2.0", "windows", "amd64")
+	if err != nil {
+		t.Fatalf("artifact not persisted: %v", err)
+	}
+	if filepath.Base(art.CachePath) != "printmaster-agent-v1.2.0-windows-amd64.exe" || art.SourceURL != "bundle:printmaster-1.2.0-offline.zip" {
+		t.Fatalf("expected plain binary from bundle, got %+v", art)
+	}
+	if data, err := os.ReadFile(art.CachePath); err != nil || string(data) != "agent-exe" {
+		t.Fatalf("cached file = %q, %v", data, err)
+	}
+	darwin, err := store.GetReleaseArtifact(context.Background(), "agent", "1.2.0", "darwin", "arm64")
+	if err != nil || filepath.Dir(filepath.Dir(filepath.Dir(filepath.Dir(darwin.CachePath)))) != worker.cacheDir {
+		t.Fatalf("archive entry should be flattened into the cache, got %+v, %v", darwin, err)
+	}
+}
+
+func TestOfflineWorkerImportsDirectory(t *testing.T) {
+	t.Parallel()
+	apiHits := 0
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		apiHits++
+		http.Error(w, "offline", http.StatusServiceUnavailable)
+	}))
+	t.Cleanup(api.Close)
+
+	importDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(importDir, "printmaster-agent-v2.0.0-linux-arm64"), []byte("bin"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	worker, store := newBundleWorker(t, Options{
+		BaseAPIURL:   api.URL,
+		HTTPClient:   api.Client(),
+		PollInterval: time.Hour,
+		Offline:      true,
+		ImportDir:    importDir,
+	})
+
+	var last SyncProgress
+	if err := worker.RunOnceWithProgress(context.Background(), func(p SyncProgress) { last = p }); err != nil {
+		t.Fatalf("offline sync: %v", err)
+	}
+	if apiHits != 0 {
+		t.Fatalf("offline worker contacted GitHub %d times", apiHits)
+	}
+	if last.Phase != "complete" || last.CompletedFiles != 1 {
+		t.Fatalf("unexpected final progress %+v", last)
+	}
+	if _, err := store.GetReleaseArtifact(context.Background(), "agent", "2.0.0", "linux", "arm64"); err != nil {
+		t.Fatalf("artifact not imported: %v", err)
+	}
+}
+
+func TestNewHTTPClientUsesProxy(t *testing.T) {
+	t.Parallel()
+	var gotURL, gotAuth string
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotURL = r.URL.String()
+		gotAuth = r.Header.Get("Proxy-Authorization")
+		_, _ = w.Write([]byte("[]"))
+	}))
+	t.Cleanup(proxy.Close)
+
+	client, err := NewHTTPClient(ProxyConfig{URL: proxy.Listener.Addr().String(), Username: "svc", Password: "secret"}, time.Second)
+	if err != nil {
+		t.Fatalf("NewHTTPClient: %v", err)
+	}
+	resp, err := client.Get("http://releases.example.invalid/repos")
+	if err != nil {
+		t.Fatalf("request via proxy: %v", err)
+	}
+	resp.Body.Close()
+	if gotURL != "http://releases.example.invalid/repos" || gotAuth != "Basic c3ZjOnNlY3JldA==" {
+		t.Fatalf("proxy saw url=%q auth=%q", gotURL, gotAuth)
+	}
+
+	if _, err := NewHTTPClient(ProxyConfig{URL: "socks5://proxy:1080"}, 0); err == nil {
+		t.Fatal("expected socks proxy to be rejected")
+	}
+	if got := (ProxyConfig{URL: "user:pw@proxy.corp:3128"}).Redacted(); got != "http://proxy.corp:3128" {
+		t.Fatalf("Redacted() = %q", got)
+	}
+}
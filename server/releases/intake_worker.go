@@ -60,6 +60,8 @@ type Options struct {
 	IncludePrerelease bool // If true, include prerelease/dev builds in synced releases
 	UserAgent         string
 	ManifestManager   *Manager
+	Offline           bool   // Never contact GitHub; artifacts arrive via ImportBundle
+	ImportDir         string // Offline mode: directory scanned for release bundles on sync
 }
 
 type IntakeWorker struct {
@@ -77,6 +79,8 @@ type IntakeWorker struct {
 	token             string
 	userAgent         string
 	manifests         *Manager
+	offline           bool
+	importDir         string
 }
 
 type ghRelease struct {
@@ -166,11 +170,23 @@ func NewIntakeWorker(store storage.Store, log *logger.Logger, opts Options) (*In
 		token:             strings.TrimSpace(opts.GitHubToken),
 		userAgent:         userAgent,
 		manifests:         opts.ManifestManager,
+		offline:           opts.Offline,
+		importDir:         strings.TrimSpace(opts.ImportDir),
 	}, nil
 }
 
-// Run starts the periodic release intake loop.
+// Run starts the periodic release intake loop. In offline mode it imports the
+// configured import directory once and returns; further imports are manual.
 func (w *IntakeWorker) Run(ctx context.Context) {
+	if w.offline {
+		w.logInfo("release intake offline; GitHub polling disabled", "cache_dir", w.cacheDir, "import_dir", w.importDir)
+		if w.importDir != "" {
+			if err := w.importDirectory(ctx, nil); err != nil {
+				w.logWarn("initial bundle import failed", "error", err)
+			}
+		}
+		return
+	}
 	w.logInfo("release intake worker started", "cache_dir", w.cacheDir, "interval", w.pollInterval.String())
 	if err := w.runOnce(ctx); err != nil {
 		w.logWarn("initial release intake failed", "error", err)
@@ -210,7 +226,20 @@ type artifactPlan struct {
 	needsWork bool // false if already cached
 }
 
+// CacheDir returns the directory release artifacts are cached in.
+func (w *IntakeWorker) CacheDir() string {
+	return w.cacheDir
+}
+
+// Offline reports whether the worker is in air-gapped mode.
+func (w *IntakeWorker) Offline() bool {
+	return w.offline
+}
+
 func (w *IntakeWorker) runOnceWithProgress(ctx context.Context, onProgress ProgressCallback) error {
+	if w.offline {
+		return w.importDirectory(ctx, onProgress)
+	}
 	report := func(p SyncProgress) {
 		if onProgress != nil {
 			onProgress(p)
@@ -526,6 +555,9 @@ func (w *IntakeWorker) downloadArtifactWithProgress(ctx context.Context, desc ar
 }
 
 func (w *IntakeWorker) runOnce(ctx context.Context) error {
+	if w.offline {
+		return w.importDirectory(ctx, nil)
+	}
 	releases, err := w.fetchReleases(ctx)
 	if err != nil {
 		return err
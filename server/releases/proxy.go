@@ -0,0 +1,75 @@
+package releases
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// ProxyConfig describes the outbound proxy used for release fetches.
+// An empty URL falls back to the HTTPS_PROXY/HTTP_PROXY/NO_PROXY environment.
+type ProxyConfig struct {
+	URL      string
+	Username string
+	Password string
+}
+
+// NewHTTPClient builds the client used for GitHub API calls and artifact
+// downloads, routing traffic through the configured proxy.
+func NewHTTPClient(cfg ProxyConfig, timeout time.Duration) (*http.Client, error) {
+	proxy, err := cfg.proxyFunc()
+	if err != nil {
+		return nil, err
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = proxy
+	if timeout <= 0 {
+		timeout = 2 * time.Minute
+	}
+	return &http.Client{Timeout: timeout, Transport: transport}, nil
+}
+
+func (cfg ProxyConfig) proxyFunc() (func(*http.Request) (*url.URL, error), error) {
+	raw := cfg.normalizedURL()
+	if raw == "" {
+		return http.ProxyFromEnvironment, nil
+	}
+	u, err := url.Parse(raw)
+	if err != nil {
+		return nil, fmt.Errorf("invalid proxy url: %w", err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, fmt.Errorf("unsupported proxy scheme %q", u.Scheme)
+	}
+	if u.Host == "" {
+		return nil, fmt.Errorf("proxy url %q has no host", cfg.URL)
+	}
+	if cfg.Username != "" {
+		u.User = url.UserPassword(cfg.Username, cfg.Password)
+	}
+	return http.ProxyURL(u), nil
+}
+
+// Redacted returns the proxy URL without credentials, suitable for logging.
+func (cfg ProxyConfig) Redacted() string {
+	raw := cfg.normalizedURL()
+	if raw == "" {
+		return ""
+	}
+	u, err := url.Parse(raw)
+	if err != nil {
+		return "(invalid)"
+	}
+	u.User = nil
+	return u.String()
+}
+
+func (cfg ProxyConfig) normalizedURL() string {
+	raw := strings.TrimSpace(cfg.URL)
+	if raw != "" && !strings.Contains(raw, "://") {
+		raw = "http://" + raw
+	}
+	return raw
+}
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"mime/multipart"
+	"net/smtp"
+	"net/textproto"
+	"strings"
+
+	"printmaster/server/reports"
+	"printmaster/server/storage"
+)
+
+// reportScheduler runs due report schedules in the background. It is nil
+// until the server starts.
+var reportScheduler *reports.Scheduler
+
+// newReportScheduler returns a scheduler that emails finished reports over
+// the configured SMTP server.
+func newReportScheduler() *reports.Scheduler {
+	s := reports.NewScheduler(&ReportStore{store: serverStore}, serverLogger)
+	s.SetDelivery(emailReportRun)
+	return s
+}
+
+// reportFileType returns the MIME type and file extension of a report format.
+func reportFileType(format string) (contentType, ext string) {
+	switch format {
+	case storage.ReportFormatJSON:
+		return "application/json", "json"
+	case storage.ReportFormatCSV:
+		return "text/csv", "csv"
+	case storage.ReportFormatHTML:
+		return "text/html", "html"
+	case storage.ReportFormatXLSX:
+		return "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet", "xlsx"
+	case storage.ReportFormatPDF:
+		return "application/pdf", "pdf"
+	default:
+		return "application/octet-stream", "txt"
+	}
+}
+
+// reportRunFilename names a run's result file for downloads and attachments.
+func reportRunFilename(run *storage.ReportRun) string {
+	_, ext := reportFileType(run.Format)
+	return fmt.Sprintf("report_%d_%s.%s", run.ReportID, run.StartedAt.Format("20060102_150405"), ext)
+}
+
+// emailReportRun sends a finished report to its recipients as an attachment.
+func emailReportRun(ctx context.Context, report *storage.ReportDefinition, run *storage.ReportRun, data []byte) error {
+	host, port, user, pass, from, err := smtpSettings()
+	if err != nil {
+		return err
+	}
+	var to []string
+	for _, addr := range report.EmailRecipients {
+		validated, err := validateEmailAddress(addr)
+		if err != nil {
+			return fmt.Errorf("invalid recipient address %q: %w", addr, err)
+		}
+		to = append(to, validated)
+	}
+	if len(to) == 0 {
+		return fmt.Errorf("no recipients")
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	var body strings.Builder
+	fmt.Fprintf(&body, "The scheduled report %q has been generated.\r\n\r\n", report.Name)
+	fmt.Fprintf(&body, "Report type: %s\r\n", report.Type)
+	fmt.Fprintf(&body, "Rows: %d\r\n", run.RowCount)
+	fmt.Fprintf(&body, "Generated: %s\r\n", run.StartedAt.UTC().Format("2006-01-02 15:04 MST"))
+	if len(report.TenantIDs) > 0 {
+		fmt.Fprintf(&body, "Tenants: %s\r\n", strings.Join(report.TenantIDs, ", "))
+	}
+	body.WriteString("\r\nThe report is attached and can also be downloaded from the Reports page.\r\n")
+
+	contentType, _ := reportFileType(run.Format)
+	msg, err := buildReportEmail(sanitizeEmailHeader(from), to, "PrintMaster report: "+report.Name,
+		body.String(), reportRunFilename(run), contentType, data)
+	if err != nil {
+		return err
+	}
+	auth := smtp.PlainAuth("", user, pass, host)
+	return smtp.SendMail(fmt.Sprintf("%s:%d", host, port), auth, sanitizeEmailHeader(from), to, msg)
+}
+
+// buildReportEmail builds a multipart/mixed message with a plain-text body
+// and one base64 attachment. Header values are sanitized against injection.
+func buildReportEmail(from string, to []string, subject, textBody, filename, contentType string, attachment []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	mw := multipart.NewWriter(&buf)
+	recipients := make([]string, len(to))
+	for i, addr := range to {
+		recipients[i] = sanitizeEmailHeader(addr)
+	}
+	buf.WriteString("From: " + sanitizeEmailHeader(from) + "\r\n" +
+		"To: " + strings.Join(recipients, ", ") + "\r\n" +
+		"Subject: " + sanitizeEmailHeader(subject) + "\r\n" +
+		"MIME-Version: 1.0\r\n" +
+		"Content-Type: multipart/mixed; boundary=\"" + mw.Boundary() + "\"\r\n" +
+		"\r\n")
+
+	text, err := mw.CreatePart(textproto.MIMEHeader{
+		"Content-Type":              {"text/plain; charset=utf-8"},
+		"Content-Transfer-Encoding": {"8bit"},
+	})
+	if err != nil {
+		return nil, err
+	}
+	text.Write([]byte(textBody))
+
+	filename = strings.ReplaceAll(sanitizeEmailHeader(filename), `"`, "")
+	file, err := mw.CreatePart(textproto.MIMEHeader{
+		"Content-Type":              {contentType + "; name=\"" + filename + "\""},
+		"Content-Disposition":       {"attachment; filename=\"" + filename + "\""},
+		"Content-Transfer-Encoding": {"base64"},
+	})
+	if err != nil {
+		return nil, err
+	}
+	// RFC 2045 limits encoded lines to 76 characters
+	encoded := base64.StdEncoding.EncodeToString(attachment)
+	for len(encoded) > 76 {
+		file.Write([]byte(encoded[:76] + "\r\n"))
+		encoded = encoded[76:]
+	}
+	file.Write([]byte(encoded + "\r\n"))
+
+	if err := mw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
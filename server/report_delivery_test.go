@@ -0,0 +1,156 @@
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/mail"
+	"strconv"
+	"strings"
+	"testing"
+
+	"printmaster/common/logger"
+	"printmaster/server/storage"
+)
+
+func TestBuildReportEmail(t *testing.T) {
+	attachment := bytes.Repeat([]byte("%PDF-1.4 report "), 20)
+	raw, err := buildReportEmail("PrintMaster <pm@example.com>", []string{"ops@example.com", "billing@example.com"},
+		"PrintMaster report: SLA\r\nBcc: evil@example.com", "Report attached.", "report_1.pdf", "application/pdf", attachment)
+	if err != nil {
+		t.Fatalf("buildReportEmail: %v", err)
+	}
+
+	msg, err := mail.ReadMessage(bytes.NewReader(raw))
+	if err != nil {
+		t.Fatalf("parse message: %v", err)
+	}
+	if msg.Header.Get("Bcc") != "" || !strings.HasPrefix(msg.Header.Get("Subject"), "PrintMaster report: SLA") {
+		t.Errorf("subject was not sanitized: %q", msg.Header)
+	}
+	if to := msg.Header.Get("To"); to != "ops@example.com, billing@example.com" {
+		t.Errorf("To = %q", to)
+	}
+	mediaType, params, err := mime.ParseMediaType(msg.Header.Get("Content-Type"))
+	if err != nil || mediaType != "multipart/mixed" {
+		t.Fatalf("Content-Type = %q (%v)", msg.Header.Get("Content-Type"), err)
+	}
+
+	mr := multipart.NewReader(msg.Body, params["boundary"])
+	body, err := mr.NextPart()
+	if err != nil {
+		t.Fatalf("text part: %v", err)
+	}
+	if text, _ := io.ReadAll(body); string(text) != "Report attached." {
+		t.Errorf("text part = %q", text)
+	}
+	file, err := mr.NextRawPart()
+	if err != nil {
+		t.Fatalf("attachment part: %v", err)
+	}
+	if file.FileName() != "report_1.pdf" || file.Header.Get("Content-Type") != `application/pdf; name="report_1.pdf"` {
+		t.Errorf("unexpected attachment headers: %v", file.Header)
+	}
+	encoded, _ := io.ReadAll(file)
+	for _, line := range strings.Split(strings.TrimSpace(string(encoded)), "\r\n") {
+		if len(line) > 76 {
+			t.Fatalf("base64 line longer than 76 characters: %d", len(line))
+		}
+	}
+	decoded, err := base64.StdEncoding.DecodeString(strings.NewReplacer("\r", "", "\n", "").Replace(string(encoded)))
+	if err != nil || !bytes.Equal(decoded, attachment) {
+		t.Errorf("attachment did not round trip: %v", err)
+	}
+}
+
+func TestReportSchedulesCreateAndRun(t *testing.T) {
+	SetupTestStore(t)
+	prevConfig, prevLogger := serverConfig, serverLogger
+	t.Cleanup(func() { serverConfig, serverLogger = prevConfig, prevLogger })
+	serverConfig, serverLogger = nil, logger.New(logger.ERROR, "", 10)
+	for _, key := range []string{"SMTP_HOST", "SMTP_PORT"} {
+		t.Setenv(key, "")
+	}
+
+	operator := NewTestUser(storage.RoleOperator, "tenant-a")
+	post := func(user *storage.User, body string) *httptest.ResponseRecorder {
+		req := InjectTestUser(httptest.NewRequest(http.MethodPost, "/api/v1/report-schedules", strings.NewReader(body)), user)
+		rr := httptest.NewRecorder()
+		handleReportSchedulesCollection(rr, req)
+		return rr
+	}
+
+	if rr := post(NewTestUser(storage.RoleViewer, "tenant-a"), `{"name":"SLA","report_type":"availability","frequency":"monthly"}`); rr.Code != http.StatusForbidden {
+		t.Errorf("viewer should not schedule reports, got %d", rr.Code)
+	}
+	if rr := post(operator, `{"name":"SLA","report_type":"availability","frequency":"monthly","tenant_ids":["tenant-b"]}`); rr.Code != http.StatusForbidden {
+		t.Errorf("foreign tenant: expected 403, got %d", rr.Code)
+	}
+	if rr := post(operator, `{"name":"SLA","report_type":"availability","frequency":"monthly","email_recipients":["not an address"]}`); rr.Code != http.StatusBadRequest {
+		t.Errorf("bad recipient: expected 400, got %d", rr.Code)
+	}
+	if rr := post(operator, `{"name":"SLA","report_type":"nope","frequency":"monthly"}`); rr.Code != http.StatusBadRequest {
+		t.Errorf("unknown type: expected 400, got %d", rr.Code)
+	}
+
+	rr := post(operator, `{"name":"Monthly SLA","report_type":"availability","output_format":"pdf","time_range":"last_month",
+		"frequency":"monthly","day_of_month":2,"time_of_day":"06:30","email_recipients":["ops@example.com, billing@example.com"]}`)
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("create failed: %d %s", rr.Code, rr.Body.String())
+	}
+	var created scheduleView
+	if err := json.NewDecoder(rr.Body).Decode(&created); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if created.ReportType != storage.ReportTypeAvailability || created.Format != storage.ReportFormatPDF ||
+		len(created.TenantIDs) != 1 || created.TenantIDs[0] != "tenant-a" || len(created.EmailRecipients) != 2 ||
+		created.NextRunAt.IsZero() || !created.Enabled {
+		t.Fatalf("unexpected schedule: %+v", created)
+	}
+
+	// Other tenants don't see the schedule
+	list := func(user *storage.User) []scheduleView {
+		req := InjectTestUser(httptest.NewRequest(http.MethodGet, "/api/v1/report-schedules", nil), user)
+		rr := httptest.NewRecorder()
+		handleReportSchedulesCollection(rr, req)
+		var resp struct {
+			Schedules []scheduleView `json:"schedules"`
+		}
+		json.NewDecoder(rr.Body).Decode(&resp)
+		return resp.Schedules
+	}
+	if got := list(operator); len(got) != 1 || got[0].ReportName != "Monthly SLA" {
+		t.Errorf("operator schedules: %+v", got)
+	}
+	if got := list(NewTestUser(storage.RoleOperator, "tenant-b")); len(got) != 0 {
+		t.Errorf("schedule leaked to another tenant: %+v", got)
+	}
+
+	// Run now: the report is stored even though SMTP isn't configured
+	path := "/api/v1/report-schedules/" + strconv.FormatInt(created.ID, 10) + "/run"
+	req := InjectTestUser(httptest.NewRequest(http.MethodPost, path, nil), operator)
+	rr = httptest.NewRecorder()
+	handleSchedule(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("run failed: %d %s", rr.Code, rr.Body.String())
+	}
+	var run storage.ReportRun
+	if err := json.NewDecoder(rr.Body).Decode(&run); err != nil {
+		t.Fatalf("decode run: %v", err)
+	}
+	if run.Status != storage.ReportStatusCompleted || run.ScheduleID == nil || !strings.Contains(run.DeliveryError, "SMTP not configured") {
+		t.Errorf("unexpected run: %+v", run)
+	}
+
+	req = InjectTestUser(httptest.NewRequest(http.MethodGet, "/api/v1/report-runs/"+strconv.FormatInt(run.ID, 10)+"/download", nil), operator)
+	rr = httptest.NewRecorder()
+	handleReportRunResult(rr, req)
+	if rr.Code != http.StatusOK || rr.Header().Get("Content-Type") != "application/pdf" || !strings.HasPrefix(rr.Body.String(), "%PDF-") {
+		t.Errorf("download: %d %q", rr.Code, rr.Header().Get("Content-Type"))
+	}
+}
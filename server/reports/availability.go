@@ -0,0 +1,221 @@
+package reports
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"printmaster/server/storage"
+)
+
+// ---------- Availability (SLA) Reports ----------
+
+// AvailabilityOptions configures the availability report. It is read from
+// the report's OptionsJSON.
+type AvailabilityOptions struct {
+	// TargetPct is the uptime a device must reach to meet the SLA.
+	TargetPct float64 `json:"target_pct,omitempty"`
+}
+
+// ParseAvailabilityOptions decodes availability options and fills in
+// defaults.
+func ParseAvailabilityOptions(raw string) (*AvailabilityOptions, error) {
+	opts := &AvailabilityOptions{}
+	if strings.TrimSpace(raw) != "" {
+		if err := json.Unmarshal([]byte(raw), opts); err != nil {
+			return nil, fmt.Errorf("invalid availability options: %w", err)
+		}
+	}
+	if opts.TargetPct > 100 {
+		return nil, fmt.Errorf("invalid availability options: target_pct must be at most 100")
+	}
+	if opts.TargetPct <= 0 {
+		opts.TargetPct = 99
+	}
+	return opts, nil
+}
+
+// Downtime is the time a device spent offline within a window.
+type Downtime struct {
+	Window  time.Duration // window length, from the device's first sighting
+	Down    time.Duration
+	Events  int // device_offline alerts overlapping the window
+	Longest time.Duration
+}
+
+// UptimePct returns the share of the window the device was online, rounded
+// to two decimals.
+func (d Downtime) UptimePct() float64 {
+	if d.Window <= 0 {
+		return 100
+	}
+	return math.Round((1-float64(d.Down)/float64(d.Window))*10000) / 100
+}
+
+// OfflineDowntime measures a device's downtime in [from, to] from its
+// device_offline alerts. Alerts still open count until to; the window starts
+// no earlier than firstSeen. Overlapping alerts are merged so repeated
+// triggers are not double counted.
+func OfflineDowntime(offline []*storage.Alert, serial string, firstSeen, from, to time.Time) Downtime {
+	windowStart := from
+	if firstSeen.After(windowStart) {
+		windowStart = firstSeen
+	}
+	d := Downtime{Window: to.Sub(windowStart)}
+	if d.Window <= 0 {
+		d.Window = 0
+		return d
+	}
+
+	type span struct{ start, end time.Time }
+	var spans []span
+	for _, a := range offline {
+		if a == nil || a.DeviceSerial != serial {
+			continue
+		}
+		end := to
+		if a.ResolvedAt != nil && a.ResolvedAt.Before(to) {
+			end = *a.ResolvedAt
+		}
+		start := a.TriggeredAt
+		if start.Before(windowStart) {
+			start = windowStart
+		}
+		if !end.After(start) {
+			continue
+		}
+		spans = append(spans, span{start, end})
+	}
+	d.Events = len(spans)
+
+	sort.Slice(spans, func(i, j int) bool { return spans[i].start.Before(spans[j].start) })
+	var cur *span
+	closeSpan := func() {
+		if cur == nil {
+			return
+		}
+		length := cur.end.Sub(cur.start)
+		d.Down += length
+		d.Longest = max(d.Longest, length)
+	}
+	for i := range spans {
+		s := spans[i]
+		if cur != nil && !s.start.After(cur.end) {
+			if s.end.After(cur.end) {
+				cur.end = s.end
+			}
+			continue
+		}
+		closeSpan()
+		cur = &s
+	}
+	closeSpan()
+	return d
+}
+
+func (g *Generator) generateAvailability(ctx context.Context, params GenerateParams) (*GenerateResult, error) {
+	opts, err := ParseAvailabilityOptions(params.Report.OptionsJSON)
+	if err != nil {
+		return nil, err
+	}
+	devices, err := g.store.ListAllDevices(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("list devices: %w", err)
+	}
+	if devices, err = g.filterDevices(ctx, devices, params.Report); err != nil {
+		return nil, err
+	}
+	agents, err := g.store.ListAgents(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("list agents: %w", err)
+	}
+	agentTenant := make(map[string]string, len(agents))
+	for _, a := range agents {
+		agentTenant[a.AgentID] = a.TenantID
+	}
+
+	end := params.EndTime
+	if end.IsZero() {
+		end = time.Now().UTC()
+	}
+	start := params.StartTime
+	if start.IsZero() {
+		start = end.AddDate(0, 0, -daysPerMonth)
+	}
+	// Outages may have started before the period, so alerts are not limited
+	// by trigger time
+	offline, err := g.store.ListAlerts(ctx, storage.AlertFilter{Type: storage.AlertTypeDeviceOffline})
+	if err != nil {
+		return nil, fmt.Errorf("list offline alerts: %w", err)
+	}
+
+	var rows []map[string]any
+	var window, down time.Duration
+	events, belowTarget := 0, 0
+	for _, d := range devices {
+		if d == nil || d.Serial == "" || !d.FirstSeen.IsZero() && !d.FirstSeen.Before(end) {
+			continue
+		}
+		dt := OfflineDowntime(offline, d.Serial, d.FirstSeen, start, end)
+		uptime := dt.UptimePct()
+		meets := uptime >= opts.TargetPct
+		if !meets {
+			belowTarget++
+		}
+		window += dt.Window
+		down += dt.Down
+		events += dt.Events
+		rows = append(rows, map[string]any{
+			"serial":                 d.Serial,
+			"tenant_id":              agentTenant[d.AgentID],
+			"manufacturer":           d.Manufacturer,
+			"model":                  d.Model,
+			"location":               d.Location,
+			"uptime_pct":             uptime,
+			"downtime_minutes":       int64(dt.Down.Minutes()),
+			"offline_events":         dt.Events,
+			"longest_outage_minutes": int64(dt.Longest.Minutes()),
+			"meets_target":           meets,
+		})
+	}
+
+	// Worst devices first
+	sort.SliceStable(rows, func(i, j int) bool {
+		ui, uj := rows[i]["uptime_pct"].(float64), rows[j]["uptime_pct"].(float64)
+		if ui != uj {
+			return ui < uj
+		}
+		return rows[i]["serial"].(string) < rows[j]["serial"].(string)
+	})
+	deviceCount := len(rows)
+	if params.Report.Limit > 0 && len(rows) > params.Report.Limit {
+		rows = rows[:params.Report.Limit]
+	}
+
+	fleetUptime := Downtime{Window: window, Down: down}.UptimePct()
+	return &GenerateResult{
+		Rows: rows,
+		Columns: []string{
+			"serial", "tenant_id", "manufacturer", "model", "location", "uptime_pct",
+			"downtime_minutes", "offline_events", "longest_outage_minutes", "meets_target",
+		},
+		RowCount: len(rows),
+		Summary: map[string]any{
+			"period_start":           start,
+			"period_end":             end,
+			"target_pct":             opts.TargetPct,
+			"devices":                deviceCount,
+			"devices_below_target":   belowTarget,
+			"fleet_uptime_pct":       fleetUptime,
+			"total_downtime_minutes": int64(down.Minutes()),
+			"offline_events":         events,
+		},
+		Metadata: map[string]string{
+			"generated": time.Now().UTC().Format(time.RFC3339),
+		},
+	}, nil
+}
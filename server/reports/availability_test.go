@@ -0,0 +1,105 @@
+package reports
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"printmaster/server/storage"
+)
+
+func TestOfflineDowntime(t *testing.T) {
+	t.Parallel()
+
+	from := time.Date(2026, time.September, 1, 0, 0, 0, 0, time.UTC)
+	to := from.AddDate(0, 0, 10)
+	at := func(day, hour int) time.Time { return from.AddDate(0, 0, day).Add(time.Duration(hour) * time.Hour) }
+	resolved := func(tm time.Time) *time.Time { return &tm }
+
+	offline := []*storage.Alert{
+		// Started before the window; only the part inside counts
+		{DeviceSerial: "SN1", TriggeredAt: from.Add(-2 * time.Hour), ResolvedAt: resolved(at(0, 1))},
+		// Overlapping triggers are merged into one 3 hour outage
+		{DeviceSerial: "SN1", TriggeredAt: at(2, 0), ResolvedAt: resolved(at(2, 2))},
+		{DeviceSerial: "SN1", TriggeredAt: at(2, 1), ResolvedAt: resolved(at(2, 3))},
+		// Still open at the end of the window
+		{DeviceSerial: "SN1", TriggeredAt: at(9, 20)},
+		{DeviceSerial: "SN2", TriggeredAt: at(1, 0), ResolvedAt: resolved(at(5, 0))},
+		// Resolved before the window
+		{DeviceSerial: "SN1", TriggeredAt: from.AddDate(0, 0, -3), ResolvedAt: resolved(from.AddDate(0, 0, -2))},
+	}
+
+	d := OfflineDowntime(offline, "SN1", time.Time{}, from, to)
+	if d.Window != 240*time.Hour || d.Down != 8*time.Hour || d.Events != 4 || d.Longest != 4*time.Hour {
+		t.Fatalf("unexpected downtime: %+v", d)
+	}
+	if got := d.UptimePct(); got != 96.67 {
+		t.Errorf("UptimePct = %v, want 96.67", got)
+	}
+
+	// A device first seen mid-window is only measured from then on
+	d = OfflineDowntime(offline, "SN1", at(5, 0), from, to)
+	if d.Window != 120*time.Hour || d.Down != 4*time.Hour || d.Events != 1 {
+		t.Errorf("unexpected downtime from first sighting: %+v", d)
+	}
+	if d := OfflineDowntime(offline, "SN1", to.Add(time.Hour), from, to); d.Window != 0 || d.UptimePct() != 100 {
+		t.Errorf("device seen after the window: %+v", d)
+	}
+}
+
+func TestGenerator_Availability(t *testing.T) {
+	t.Parallel()
+
+	from := time.Date(2026, time.September, 1, 0, 0, 0, 0, time.UTC)
+	to := time.Date(2026, time.October, 1, 0, 0, 0, 0, time.UTC)
+	resolved := func(tm time.Time) *time.Time { return &tm }
+
+	store := newMockGeneratorStore()
+	store.agents = []*storage.Agent{
+		{AgentID: "agent-1", TenantID: "tenant-a"},
+		{AgentID: "agent-2", TenantID: "tenant-b"},
+	}
+	store.devices = []*storage.Device{
+		newTestDevice("UP", "LaserJet M404", "10.0.0.1", "agent-1"),
+		newTestDevice("FLAKY", "LaserJet M404", "10.0.0.2", "agent-1"),
+		newTestDevice("OTHER", "LaserJet M404", "10.1.0.1", "agent-2"),
+	}
+	for _, d := range store.devices {
+		d.FirstSeen = from.AddDate(0, -1, 0)
+	}
+	store.alerts = []*storage.Alert{
+		{Type: storage.AlertTypeDeviceOffline, DeviceSerial: "FLAKY", TriggeredAt: from.AddDate(0, 0, 3), ResolvedAt: resolved(from.AddDate(0, 0, 4))},
+		{Type: storage.AlertTypeDeviceOffline, DeviceSerial: "OTHER", TriggeredAt: from.AddDate(0, 0, 3)},
+	}
+
+	if _, err := ParseAvailabilityOptions(`{"target_pct":101}`); err == nil {
+		t.Fatal("expected a target above 100% to be rejected")
+	}
+	result, err := NewGenerator(store).Generate(context.Background(), GenerateParams{
+		Report: &storage.ReportDefinition{
+			Type:        storage.ReportTypeAvailability,
+			TenantIDs:   []string{"tenant-a"},
+			OptionsJSON: `{"target_pct":99.5}`,
+		},
+		StartTime: from,
+		EndTime:   to,
+	})
+	if err != nil {
+		t.Fatalf("Generate failed: %v", err)
+	}
+
+	if len(result.Rows) != 2 {
+		t.Fatalf("expected only tenant-a devices, got %+v", result.Rows)
+	}
+	worst := result.Rows[0]
+	if worst["serial"] != "FLAKY" || worst["uptime_pct"] != 96.67 || worst["downtime_minutes"] != int64(1440) ||
+		worst["offline_events"] != 1 || worst["meets_target"] != false || worst["tenant_id"] != "tenant-a" {
+		t.Errorf("unexpected worst row: %+v", worst)
+	}
+	if best := result.Rows[1]; best["serial"] != "UP" || best["uptime_pct"] != 100.0 || best["meets_target"] != true {
+		t.Errorf("unexpected best row: %+v", best)
+	}
+	if result.Summary["devices_below_target"] != 1 || result.Summary["fleet_uptime_pct"] != 98.33 || result.Summary["target_pct"] != 99.5 {
+		t.Errorf("unexpected summary: %+v", result.Summary)
+	}
+}
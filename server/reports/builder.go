@@ -0,0 +1,581 @@
+package reports
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"printmaster/server/storage"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Entities a custom (report builder) report can be built from.
+const (
+	BuilderEntityDevices = "devices"
+	BuilderEntityMetrics = "metrics"
+	BuilderEntityAgents  = "agents"
+)
+
+// Field types used by the report builder for filtering and sorting.
+const (
+	BuilderFieldString = "string"
+	BuilderFieldNumber = "number"
+	BuilderFieldTime   = "time"
+)
+
+// builderCountColumn is added to every grouped result.
+const builderCountColumn = "count"
+
+// BuilderField describes a column the report builder can select, filter,
+// group or sort on.
+type BuilderField struct {
+	Name  string `json:"name"`
+	Label string `json:"label"`
+	Type  string `json:"type"`
+}
+
+// BuilderFilter is a single filter condition. Filters are combined with AND.
+type BuilderFilter struct {
+	Field string `json:"field"`
+	Op    string `json:"op"`
+	Value string `json:"value,omitempty"`
+}
+
+// BuilderOptions is the custom report configuration stored in the report's
+// OptionsJSON. Columns, grouping, ordering and limit use the regular report
+// definition fields.
+type BuilderOptions struct {
+	Entity  string          `json:"entity"`
+	Filters []BuilderFilter `json:"filters,omitempty"`
+}
+
+// BuilderFilterOps lists the supported filter operators.
+var BuilderFilterOps = []string{"eq", "neq", "contains", "gt", "gte", "lt", "lte", "empty", "not_empty"}
+
+var builderFields = map[string][]BuilderField{
+	BuilderEntityDevices: {
+		{Name: "serial", Label: "Serial", Type: BuilderFieldString},
+		{Name: "ip", Label: "IP Address", Type: BuilderFieldString},
+		{Name: "manufacturer", Label: "Manufacturer", Type: BuilderFieldString},
+		{Name: "model", Label: "Model", Type: BuilderFieldString},
+		{Name: "hostname", Label: "Hostname", Type: BuilderFieldString},
+		{Name: "firmware", Label: "Firmware", Type: BuilderFieldString},
+		{Name: "mac_address", Label: "MAC Address", Type: BuilderFieldString},
+		{Name: "location", Label: "Location", Type: BuilderFieldString},
+		{Name: "asset_number", Label: "Asset Number", Type: BuilderFieldString},
+		{Name: "device_type", Label: "Device Type", Type: BuilderFieldString},
+		{Name: "agent_id", Label: "Agent ID", Type: BuilderFieldString},
+		{Name: "agent_name", Label: "Agent", Type: BuilderFieldString},
+		{Name: "tenant_id", Label: "Tenant", Type: BuilderFieldString},
+		{Name: "last_seen", Label: "Last Seen", Type: BuilderFieldTime},
+		{Name: "first_seen", Label: "First Seen", Type: BuilderFieldTime},
+	},
+	BuilderEntityMetrics: {
+		{Name: "serial", Label: "Serial", Type: BuilderFieldString},
+		{Name: "manufacturer", Label: "Manufacturer", Type: BuilderFieldString},
+		{Name: "model", Label: "Model", Type: BuilderFieldString},
+		{Name: "location", Label: "Location", Type: BuilderFieldString},
+		{Name: "agent_id", Label: "Agent ID", Type: BuilderFieldString},
+		{Name: "agent_name", Label: "Agent", Type: BuilderFieldString},
+		{Name: "tenant_id", Label: "Tenant", Type: BuilderFieldString},
+		{Name: "page_count", Label: "Page Count", Type: BuilderFieldNumber},
+		{Name: "color_pages", Label: "Color Pages", Type: BuilderFieldNumber},
+		{Name: "mono_pages", Label: "Mono Pages", Type: BuilderFieldNumber},
+		{Name: "scan_count", Label: "Scan Count", Type: BuilderFieldNumber},
+		{Name: "pages_in_period", Label: "Pages In Period", Type: BuilderFieldNumber},
+		{Name: "color_pages_in_period", Label: "Color Pages In Period", Type: BuilderFieldNumber},
+		{Name: "mono_pages_in_period", Label: "Mono Pages In Period", Type: BuilderFieldNumber},
+		{Name: "scans_in_period", Label: "Scans In Period", Type: BuilderFieldNumber},
+		{Name: "last_metrics_at", Label: "Last Metrics", Type: BuilderFieldTime},
+	},
+	BuilderEntityAgents: {
+		{Name: "agent_id", Label: "Agent ID", Type: BuilderFieldString},
+		{Name: "name", Label: "Name", Type: BuilderFieldString},
+		{Name: "hostname", Label: "Hostname", Type: BuilderFieldString},
+		{Name: "ip", Label: "IP Address", Type: BuilderFieldString},
+		{Name: "platform", Label: "Platform", Type: BuilderFieldString},
+		{Name: "version", Label: "Version", Type: BuilderFieldString},
+		{Name: "os_version", Label: "OS Version", Type: BuilderFieldString},
+		{Name: "architecture", Label: "Architecture", Type: BuilderFieldString},
+		{Name: "status", Label: "Status", Type: BuilderFieldString},
+		{Name: "tenant_id", Label: "Tenant", Type: BuilderFieldString},
+		{Name: "device_count", Label: "Device Count", Type: BuilderFieldNumber},
+		{Name: "last_seen", Label: "Last Seen", Type: BuilderFieldTime},
+		{Name: "registered_at", Label: "Registered", Type: BuilderFieldTime},
+	},
+}
+
+// BuilderFields returns the fields available for an entity, or nil if the
+// entity is unknown.
+func BuilderFields(entity string) []BuilderField {
+	fields := builderFields[entity]
+	if fields == nil {
+		return nil
+	}
+	return append([]BuilderField(nil), fields...)
+}
+
+// BuilderEntities returns the supported report builder entities.
+func BuilderEntities() []string {
+	return []string{BuilderEntityDevices, BuilderEntityMetrics, BuilderEntityAgents}
+}
+
+// ParseBuilderOptions reads the builder options from a custom report.
+func ParseBuilderOptions(report *storage.ReportDefinition) (*BuilderOptions, error) {
+	opts := &BuilderOptions{}
+	if strings.TrimSpace(report.OptionsJSON) != "" {
+		if err := json.Unmarshal([]byte(report.OptionsJSON), opts); err != nil {
+			return nil, fmt.Errorf("invalid report options: %w", err)
+		}
+	}
+	opts.Entity = strings.ToLower(strings.TrimSpace(opts.Entity))
+	if opts.Entity == "" {
+		opts.Entity = BuilderEntityDevices
+	}
+	return opts, nil
+}
+
+// ValidateCustomReport checks that a custom report only references known
+// fields and operators for its entity.
+func ValidateCustomReport(report *storage.ReportDefinition) error {
+	opts, err := ParseBuilderOptions(report)
+	if err != nil {
+		return err
+	}
+	fields := builderFieldIndex(opts.Entity)
+	if fields == nil {
+		return fmt.Errorf("unsupported entity %q", opts.Entity)
+	}
+	for _, col := range report.Columns {
+		if _, ok := fields[col]; !ok {
+			return fmt.Errorf("unknown column %q for %s", col, opts.Entity)
+		}
+	}
+	for _, col := range report.GroupBy {
+		if _, ok := fields[col]; !ok {
+			return fmt.Errorf("unknown group_by column %q for %s", col, opts.Entity)
+		}
+	}
+	for _, f := range opts.Filters {
+		if _, ok := fields[f.Field]; !ok {
+			return fmt.Errorf("unknown filter field %q for %s", f.Field, opts.Entity)
+		}
+		if !isBuilderFilterOp(f.Op) {
+			return fmt.Errorf("unsupported filter operator %q", f.Op)
+		}
+		switch f.Op {
+		case "empty", "not_empty", "contains":
+		default:
+			if _, ok := parseBuilderValue(f.Value, fields[f.Field].Type); !ok {
+				return fmt.Errorf("invalid %s value %q for filter on %s", fields[f.Field].Type, f.Value, f.Field)
+			}
+		}
+	}
+	if report.OrderBy != "" {
+		col, _ := parseOrderBy(report.OrderBy)
+		if _, ok := fields[col]; !ok && !(len(report.GroupBy) > 0 && col == builderCountColumn) {
+			return fmt.Errorf("unknown order_by column %q for %s", col, opts.Entity)
+		}
+	}
+	if report.Limit < 0 {
+		return fmt.Errorf("limit must not be negative")
+	}
+	return nil
+}
+
+func (g *Generator) generateCustom(ctx context.Context, params GenerateParams) (*GenerateResult, error) {
+	report := params.Report
+	if err := ValidateCustomReport(report); err != nil {
+		return nil, err
+	}
+	opts, _ := ParseBuilderOptions(report)
+
+	agents, err := g.store.ListAgents(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("list agents: %w", err)
+	}
+	agentsByID := make(map[string]*storage.Agent, len(agents))
+	for _, a := range agents {
+		agentsByID[a.AgentID] = a
+	}
+
+	var rows []map[string]any
+	switch opts.Entity {
+	case BuilderEntityAgents:
+		rows = g.builderAgentRows(agents)
+	case BuilderEntityDevices, BuilderEntityMetrics:
+		rows, err = g.builderDeviceRows(ctx, params, opts.Entity, agentsByID)
+		if err != nil {
+			return nil, err
+		}
+	}
+	rows = filterByScope(rows, report)
+
+	fields := builderFieldIndex(opts.Entity)
+	filtered := rows[:0]
+	for _, row := range rows {
+		if matchesBuilderFilters(row, opts.Filters, fields) {
+			filtered = append(filtered, row)
+		}
+	}
+	rows = filtered
+	matched := len(rows)
+
+	columns := report.Columns
+	if len(columns) == 0 {
+		for _, f := range builderFields[opts.Entity] {
+			columns = append(columns, f.Name)
+		}
+	}
+	if len(report.GroupBy) > 0 {
+		rows, columns = groupBuilderRows(rows, report.GroupBy, columns, fields)
+	}
+
+	if report.OrderBy != "" {
+		col, desc := parseOrderBy(report.OrderBy)
+		fieldType := fields[col].Type
+		if col == builderCountColumn {
+			fieldType = BuilderFieldNumber
+		}
+		sort.SliceStable(rows, func(i, j int) bool {
+			c := compareBuilderValues(rows[i][col], rows[j][col], fieldType)
+			if desc {
+				return c > 0
+			}
+			return c < 0
+		})
+	}
+	if report.Limit > 0 && len(rows) > report.Limit {
+		rows = rows[:report.Limit]
+	}
+
+	return &GenerateResult{
+		Rows:     rows,
+		Columns:  columns,
+		RowCount: len(rows),
+		Summary: map[string]any{
+			"entity":       opts.Entity,
+			"matched_rows": matched,
+		},
+		Metadata: map[string]string{
+			"report_type": string(report.Type),
+			"entity":      opts.Entity,
+			"generated":   time.Now().UTC().Format(time.RFC3339),
+		},
+	}, nil
+}
+
+func (g *Generator) builderAgentRows(agents []*storage.Agent) []map[string]any {
+	rows := make([]map[string]any, 0, len(agents))
+	for _, a := range agents {
+		status := "offline"
+		if time.Since(a.LastSeen) < 5*time.Minute {
+			status = "online"
+		}
+		rows = append(rows, map[string]any{
+			"agent_id":      a.AgentID,
+			"name":          a.Name,
+			"hostname":      a.Hostname,
+			"ip":            a.IP,
+			"platform":      a.Platform,
+			"version":       a.Version,
+			"os_version":    a.OSVersion,
+			"architecture":  a.Architecture,
+			"status":        status,
+			"tenant_id":     a.TenantID,
+			"device_count":  a.DeviceCount,
+			"last_seen":     a.LastSeen,
+			"registered_at": a.RegisteredAt,
+		})
+	}
+	return rows
+}
+
+func (g *Generator) builderDeviceRows(ctx context.Context, params GenerateParams, entity string, agentsByID map[string]*storage.Agent) ([]map[string]any, error) {
+	devices, err := g.store.ListAllDevices(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("list devices: %w", err)
+	}
+
+	rows := make([]map[string]any, 0, len(devices))
+	for _, d := range devices {
+		row := map[string]any{
+			"serial":       d.Serial,
+			"manufacturer": d.Manufacturer,
+			"model":        d.Model,
+			"location":     d.Location,
+			"agent_id":     d.AgentID,
+			"agent_name":   "",
+			"tenant_id":    "",
+		}
+		if a := agentsByID[d.AgentID]; a != nil {
+			row["agent_name"] = a.Name
+			row["tenant_id"] = a.TenantID
+		}
+
+		if entity == BuilderEntityDevices {
+			row["ip"] = d.IP
+			row["hostname"] = d.Hostname
+			row["firmware"] = d.Firmware
+			row["mac_address"] = d.MACAddress
+			row["asset_number"] = d.AssetNumber
+			row["device_type"] = d.DeviceType
+			row["last_seen"] = d.LastSeen
+			row["first_seen"] = d.FirstSeen
+			rows = append(rows, row)
+			continue
+		}
+
+		latest, err := g.store.GetLatestMetrics(ctx, d.Serial)
+		if err != nil || latest == nil {
+			continue
+		}
+		row["page_count"] = latest.PageCount
+		row["color_pages"] = latest.ColorPages
+		row["mono_pages"] = latest.MonoPages
+		row["scan_count"] = latest.ScanCount
+		row["last_metrics_at"] = latest.Timestamp
+
+		// Usage in the report window is the delta from the last snapshot at or
+		// before the window start; devices first seen inside the window count
+		// from their earliest snapshot.
+		var baseline *storage.MetricsSnapshot
+		if !params.StartTime.IsZero() {
+			baseline, _ = g.store.GetMetricsAtOrBefore(ctx, d.Serial, params.StartTime)
+			if baseline == nil {
+				if history, err := g.store.GetMetricsHistory(ctx, d.Serial, params.StartTime); err == nil && len(history) > 0 {
+					baseline = history[0]
+				}
+			}
+		}
+		if baseline == nil {
+			baseline = latest
+		}
+		row["pages_in_period"] = nonNegative(latest.PageCount - baseline.PageCount)
+		row["color_pages_in_period"] = nonNegative(latest.ColorPages - baseline.ColorPages)
+		row["mono_pages_in_period"] = nonNegative(latest.MonoPages - baseline.MonoPages)
+		row["scans_in_period"] = nonNegative(latest.ScanCount - baseline.ScanCount)
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
+
+// filterByScope applies the report's tenant and agent restrictions to rows
+// carrying tenant_id and agent_id.
+func filterByScope(rows []map[string]any, report *storage.ReportDefinition) []map[string]any {
+	if len(report.TenantIDs) == 0 && len(report.AgentIDs) == 0 {
+		return rows
+	}
+	tenants := make(map[string]bool, len(report.TenantIDs))
+	for _, id := range report.TenantIDs {
+		tenants[id] = true
+	}
+	agents := make(map[string]bool, len(report.AgentIDs))
+	for _, id := range report.AgentIDs {
+		agents[id] = true
+	}
+	filtered := rows[:0]
+	for _, row := range rows {
+		if len(tenants) > 0 && !tenants[fmt.Sprint(row["tenant_id"])] {
+			continue
+		}
+		if len(agents) > 0 && !agents[fmt.Sprint(row["agent_id"])] {
+			continue
+		}
+		filtered = append(filtered, row)
+	}
+	return filtered
+}
+
+func matchesBuilderFilters(row map[string]any, filters []BuilderFilter, fields map[string]BuilderField) bool {
+	for _, f := range filters {
+		value := row[f.Field]
+		fieldType := fields[f.Field].Type
+		empty := isEmptyBuilderValue(value)
+		switch f.Op {
+		case "empty":
+			if !empty {
+				return false
+			}
+			continue
+		case "not_empty":
+			if empty {
+				return false
+			}
+			continue
+		case "contains":
+			if !strings.Contains(strings.ToLower(formatValue(value)), strings.ToLower(f.Value)) {
+				return false
+			}
+			continue
+		}
+
+		target, ok := parseBuilderValue(f.Value, fieldType)
+		if !ok {
+			return false
+		}
+		c := compareBuilderValues(value, target, fieldType)
+		var pass bool
+		switch f.Op {
+		case "eq":
+			pass = c == 0
+		case "neq":
+			pass = c != 0
+		case "gt":
+			pass = c > 0
+		case "gte":
+			pass = c >= 0
+		case "lt":
+			pass = c < 0
+		case "lte":
+			pass = c <= 0
+		}
+		if !pass {
+			return false
+		}
+	}
+	return true
+}
+
+// groupBuilderRows collapses rows by the group columns. Each group reports its
+// row count and the sum of every selected numeric column; other columns are
+// dropped because they have no single value per group.
+func groupBuilderRows(rows []map[string]any, groupBy []string, columns []string, fields map[string]BuilderField) ([]map[string]any, []string) {
+	var sums []string
+	for _, col := range columns {
+		if fields[col].Type == BuilderFieldNumber && !slices.Contains(groupBy, col) {
+			sums = append(sums, col)
+		}
+	}
+
+	groups := make(map[string]map[string]any)
+	var order []string
+	for _, row := range rows {
+		parts := make([]string, len(groupBy))
+		for i, col := range groupBy {
+			parts[i] = formatValue(row[col])
+		}
+		key := strings.Join(parts, "\x00")
+		group, ok := groups[key]
+		if !ok {
+			group = map[string]any{builderCountColumn: 0}
+			for _, col := range groupBy {
+				group[col] = row[col]
+			}
+			for _, col := range sums {
+				group[col] = int64(0)
+			}
+			groups[key] = group
+			order = append(order, key)
+		}
+		group[builderCountColumn] = group[builderCountColumn].(int) + 1
+		for _, col := range sums {
+			n, _ := toFloat(row[col])
+			group[col] = group[col].(int64) + int64(n)
+		}
+	}
+
+	grouped := make([]map[string]any, 0, len(order))
+	for _, key := range order {
+		grouped = append(grouped, groups[key])
+	}
+	outCols := append(append(append([]string(nil), groupBy...), builderCountColumn), sums...)
+	return grouped, outCols
+}
+
+func parseOrderBy(orderBy string) (string, bool) {
+	orderBy = strings.TrimSpace(orderBy)
+	if strings.HasPrefix(orderBy, "-") {
+		return strings.TrimSpace(orderBy[1:]), true
+	}
+	parts := strings.Fields(orderBy)
+	if len(parts) == 2 {
+		return parts[0], strings.EqualFold(parts[1], "desc")
+	}
+	return orderBy, false
+}
+
+func parseBuilderValue(raw, fieldType string) (any, bool) {
+	raw = strings.TrimSpace(raw)
+	switch fieldType {
+	case BuilderFieldNumber:
+		n, err := strconv.ParseFloat(raw, 64)
+		return n, err == nil
+	case BuilderFieldTime:
+		for _, layout := range []string{time.RFC3339, "2006-01-02T15:04", "2006-01-02"} {
+			if t, err := time.Parse(layout, raw); err == nil {
+				return t, true
+			}
+		}
+		return nil, false
+	default:
+		return raw, true
+	}
+}
+
+// compareBuilderValues returns -1, 0 or 1. Strings compare case-insensitively.
+func compareBuilderValues(a, b any, fieldType string) int {
+	switch fieldType {
+	case BuilderFieldNumber:
+		x, _ := toFloat(a)
+		y, _ := toFloat(b)
+		switch {
+		case x < y:
+			return -1
+		case x > y:
+			return 1
+		}
+		return 0
+	case BuilderFieldTime:
+		x, _ := a.(time.Time)
+		y, _ := b.(time.Time)
+		return x.Compare(y)
+	default:
+		return strings.Compare(strings.ToLower(formatValue(a)), strings.ToLower(formatValue(b)))
+	}
+}
+
+func toFloat(v any) (float64, bool) {
+	switch n := v.(type) {
+	case int:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	case float64:
+		return n, true
+	}
+	return 0, false
+}
+
+func isEmptyBuilderValue(v any) bool {
+	if t, ok := v.(time.Time); ok {
+		return t.IsZero()
+	}
+	return formatValue(v) == ""
+}
+
+func isBuilderFilterOp(op string) bool {
+	return slices.Contains(BuilderFilterOps, op)
+}
+
+func builderFieldIndex(entity string) map[string]BuilderField {
+	fields, ok := builderFields[entity]
+	if !ok {
+		return nil
+	}
+	index := make(map[string]BuilderField, len(fields))
+	for _, f := range fields {
+		index[f.Name] = f
+	}
+	return index
+}
+
+func nonNegative(n int) int {
+	if n < 0 {
+		return 0
+	}
+	return n
+}
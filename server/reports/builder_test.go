@@ -0,0 +1,144 @@
+package reports
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"printmaster/server/storage"
+)
+
+func newBuilderStore() *mockGeneratorStore {
+	store := newMockGeneratorStore()
+	store.agents = []*storage.Agent{
+		{AgentID: "agent-1", Name: "Office A", TenantID: "tenant-a", LastSeen: time.Now()},
+		{AgentID: "agent-2", Name: "Office B", TenantID: "tenant-b", LastSeen: time.Now().Add(-time.Hour)},
+	}
+	store.devices = []*storage.Device{
+		newTestDevice("SN001", "LaserJet", "10.0.0.1", "agent-1"),
+		newTestDevice("SN002", "LaserJet", "10.0.0.2", "agent-1"),
+		newTestDevice("SN003", "ImageRunner", "10.0.0.3", "agent-1"),
+		newTestDevice("SN004", "LaserJet", "10.0.1.1", "agent-2"),
+	}
+	start := time.Now().Add(-30 * 24 * time.Hour)
+	for i, d := range store.devices {
+		base := &storage.MetricsSnapshot{Serial: d.Serial, Timestamp: start.Add(-time.Hour), PageCount: 1000 * (i + 1)}
+		latest := &storage.MetricsSnapshot{Serial: d.Serial, Timestamp: time.Now(), PageCount: 1000*(i+1) + 100*(i+1)}
+		store.metricsHist[d.Serial] = []*storage.MetricsSnapshot{base, latest}
+		store.metrics[d.Serial] = latest
+	}
+	return store
+}
+
+func TestGenerator_CustomDevicesFiltersAndColumns(t *testing.T) {
+	t.Parallel()
+
+	gen := NewGenerator(newBuilderStore())
+	result, err := gen.Generate(context.Background(), GenerateParams{
+		Report: &storage.ReportDefinition{
+			Type:        storage.ReportTypeCustom,
+			Columns:     []string{"serial", "model", "agent_name"},
+			OptionsJSON: `{"entity":"devices","filters":[{"field":"model","op":"contains","value":"laser"},{"field":"ip","op":"neq","value":"10.0.0.2"}]}`,
+			OrderBy:     "serial desc",
+		},
+	})
+	if err != nil {
+		t.Fatalf("Generate failed: %v", err)
+	}
+	if result.RowCount != 2 || result.Rows[0]["serial"] != "SN004" || result.Rows[1]["serial"] != "SN001" {
+		t.Fatalf("unexpected rows: %+v", result.Rows)
+	}
+	if strings.Join(result.Columns, ",") != "serial,model,agent_name" {
+		t.Fatalf("unexpected columns: %v", result.Columns)
+	}
+	if result.Rows[1]["agent_name"] != "Office A" {
+		t.Fatalf("agent name not resolved: %+v", result.Rows[1])
+	}
+}
+
+func TestGenerator_CustomMetricsGroupedByTenant(t *testing.T) {
+	t.Parallel()
+
+	gen := NewGenerator(newBuilderStore())
+	result, err := gen.Generate(context.Background(), GenerateParams{
+		Report: &storage.ReportDefinition{
+			Type:        storage.ReportTypeCustom,
+			Columns:     []string{"model", "pages_in_period"},
+			GroupBy:     []string{"model"},
+			OrderBy:     "model",
+			TenantIDs:   []string{"tenant-a"},
+			OptionsJSON: `{"entity":"metrics"}`,
+		},
+		StartTime: time.Now().Add(-30 * 24 * time.Hour),
+		EndTime:   time.Now(),
+	})
+	if err != nil {
+		t.Fatalf("Generate failed: %v", err)
+	}
+	// tenant-b's SN004 is excluded: LaserJet = 100+200, ImageRunner = 300
+	if len(result.Rows) != 2 {
+		t.Fatalf("expected 2 groups, got %+v", result.Rows)
+	}
+	if strings.Join(result.Columns, ",") != "model,count,pages_in_period" {
+		t.Fatalf("unexpected columns: %v", result.Columns)
+	}
+	first, second := result.Rows[0], result.Rows[1]
+	if first["model"] != "ImageRunner" || first["pages_in_period"] != int64(300) || first["count"] != 1 {
+		t.Fatalf("unexpected first group: %+v", first)
+	}
+	if second["model"] != "LaserJet" || second["pages_in_period"] != int64(300) || second["count"] != 2 {
+		t.Fatalf("unexpected second group: %+v", second)
+	}
+}
+
+func TestGenerator_CustomAgentsLimit(t *testing.T) {
+	t.Parallel()
+
+	gen := NewGenerator(newBuilderStore())
+	result, err := gen.Generate(context.Background(), GenerateParams{
+		Report: &storage.ReportDefinition{
+			Type:        storage.ReportTypeCustom,
+			Columns:     []string{"agent_id", "status"},
+			OrderBy:     "agent_id",
+			Limit:       1,
+			OptionsJSON: `{"entity":"agents","filters":[{"field":"status","op":"eq","value":"Offline"}]}`,
+		},
+	})
+	if err != nil {
+		t.Fatalf("Generate failed: %v", err)
+	}
+	if result.RowCount != 1 || result.Rows[0]["agent_id"] != "agent-2" {
+		t.Fatalf("unexpected rows: %+v", result.Rows)
+	}
+}
+
+func TestValidateCustomReport(t *testing.T) {
+	t.Parallel()
+
+	cases := map[string]*storage.ReportDefinition{
+		"unknown entity":   {OptionsJSON: `{"entity":"alerts"}`},
+		"unknown column":   {Columns: []string{"toner_black"}, OptionsJSON: `{"entity":"devices"}`},
+		"unknown operator": {OptionsJSON: `{"entity":"devices","filters":[{"field":"model","op":"like","value":"x"}]}`},
+		"bad number":       {OptionsJSON: `{"entity":"metrics","filters":[{"field":"page_count","op":"gt","value":"lots"}]}`},
+		"bad order_by":     {OrderBy: "count desc", OptionsJSON: `{"entity":"agents"}`},
+		"malformed json":   {OptionsJSON: `{`},
+	}
+	for name, report := range cases {
+		report.Type = storage.ReportTypeCustom
+		if err := ValidateCustomReport(report); err == nil {
+			t.Errorf("%s: expected validation error", name)
+		}
+	}
+
+	ok := &storage.ReportDefinition{
+		Type:        storage.ReportTypeCustom,
+		Columns:     []string{"model", "page_count"},
+		GroupBy:     []string{"model"},
+		OrderBy:     "count desc",
+		OptionsJSON: `{"entity":"metrics","filters":[{"field":"last_metrics_at","op":"gte","value":"2026-01-01"}]}`,
+	}
+	if err := ValidateCustomReport(ok); err != nil {
+		t.Fatalf("valid report rejected: %v", err)
+	}
+}
@@ -0,0 +1,368 @@
+package reports
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ---------- Capacity Planning Reports ----------
+
+// Capacity utilization states.
+const (
+	CapacityUnderutilized = "underutilized"
+	CapacityOK            = "ok"
+	CapacityOverutilized  = "overutilized"
+	CapacityNoData        = "no_data"
+)
+
+// daysPerMonth normalizes observed volume to a monthly figure.
+const daysPerMonth = 30
+
+// ModelCapacity describes the rated volume of a printer model. DutyCycle is
+// the manufacturer's maximum monthly volume; RecommendedMonthlyVolume is the
+// sustainable volume the device should normally run at.
+type ModelCapacity struct {
+	DutyCycle                int `json:"duty_cycle,omitempty"`
+	RecommendedMonthlyVolume int `json:"recommended_monthly_volume,omitempty"`
+}
+
+// CapacityOptions configures the capacity planning report. It is read from
+// the report's OptionsJSON. Models are matched case-insensitively, first
+// exactly and then by the longest key contained in the device model.
+type CapacityOptions struct {
+	Models                          map[string]ModelCapacity `json:"models,omitempty"`
+	DefaultRecommendedMonthlyVolume int                      `json:"default_recommended_monthly_volume,omitempty"`
+	UnderutilizedPct                float64                  `json:"underutilized_pct,omitempty"`
+	OverutilizedPct                 float64                  `json:"overutilized_pct,omitempty"`
+}
+
+// ParseCapacityOptions decodes capacity options and fills in defaults.
+func ParseCapacityOptions(raw string) (*CapacityOptions, error) {
+	opts := &CapacityOptions{}
+	if strings.TrimSpace(raw) != "" {
+		if err := json.Unmarshal([]byte(raw), opts); err != nil {
+			return nil, fmt.Errorf("invalid capacity options: %w", err)
+		}
+	}
+	if opts.DefaultRecommendedMonthlyVolume <= 0 {
+		opts.DefaultRecommendedMonthlyVolume = 5000
+	}
+	if opts.UnderutilizedPct <= 0 {
+		opts.UnderutilizedPct = 10
+	}
+	if opts.OverutilizedPct <= 0 {
+		opts.OverutilizedPct = 100
+	}
+	return opts, nil
+}
+
+// CapacityFor returns the rated capacity for a model and whether it was
+// configured explicitly. Models without a recommended volume fall back to
+// the default, so every device can be compared.
+func (o *CapacityOptions) CapacityFor(model string) (ModelCapacity, bool) {
+	lower := strings.ToLower(strings.TrimSpace(model))
+	var match ModelCapacity
+	found, bestLen := false, 0
+	for key, capacity := range o.Models {
+		k := strings.ToLower(strings.TrimSpace(key))
+		if k == "" {
+			continue
+		}
+		if k == lower {
+			match, found = capacity, true
+			break
+		}
+		if strings.Contains(lower, k) && len(k) > bestLen {
+			match, found, bestLen = capacity, true, len(k)
+		}
+	}
+	if match.RecommendedMonthlyVolume <= 0 {
+		match.RecommendedMonthlyVolume = o.DefaultRecommendedMonthlyVolume
+	}
+	return match, found
+}
+
+// classifyUtilization maps a utilization percentage to a capacity state.
+func (o *CapacityOptions) classifyUtilization(pct float64) string {
+	switch {
+	case pct < o.UnderutilizedPct:
+		return CapacityUnderutilized
+	case pct > o.OverutilizedPct:
+		return CapacityOverutilized
+	default:
+		return CapacityOK
+	}
+}
+
+// capacityDevice is the per-device working state used to pair overloaded
+// and idle devices at the same site.
+type capacityDevice struct {
+	row         map[string]any
+	serial      string
+	agentID     string
+	monthly     int
+	recommended int
+	status      string
+}
+
+func (c *capacityDevice) headroom() int {
+	return c.recommended - c.monthly
+}
+
+func (g *Generator) generateCapacityPlanning(ctx context.Context, params GenerateParams) (*GenerateResult, error) {
+	opts, err := ParseCapacityOptions(params.Report.OptionsJSON)
+	if err != nil {
+		return nil, err
+	}
+
+	devices, err := g.store.ListAllDevices(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("list devices: %w", err)
+	}
+	agents, err := g.store.ListAgents(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("list agents: %w", err)
+	}
+	if devices, err = g.filterDevices(ctx, devices, params.Report); err != nil {
+		return nil, err
+	}
+
+	agentTenant := make(map[string]string, len(agents))
+	agentName := make(map[string]string, len(agents))
+	for _, a := range agents {
+		agentTenant[a.AgentID] = a.TenantID
+		agentName[a.AgentID] = a.Name
+	}
+	tenantFilter := make(map[string]bool)
+	for _, id := range params.Report.TenantIDs {
+		tenantFilter[id] = true
+	}
+
+	end := params.EndTime
+	if end.IsZero() {
+		end = time.Now().UTC()
+	}
+	start := params.StartTime
+	if start.IsZero() {
+		start = end.AddDate(0, 0, -daysPerMonth)
+	}
+
+	statusCounts := map[string]int{
+		CapacityUnderutilized: 0,
+		CapacityOK:            0,
+		CapacityOverutilized:  0,
+		CapacityNoData:        0,
+	}
+	var totalMonthly, totalRecommended int64
+	var unconfigured []string
+	seenUnconfigured := make(map[string]bool)
+
+	working := make([]*capacityDevice, 0, len(devices))
+	for _, d := range devices {
+		if d == nil || d.Serial == "" {
+			continue
+		}
+		tenantID := agentTenant[d.AgentID]
+		if len(tenantFilter) > 0 && !tenantFilter[tenantID] {
+			continue
+		}
+
+		capacity, configured := opts.CapacityFor(d.Model)
+		if !configured && d.Model != "" && !seenUnconfigured[d.Model] {
+			seenUnconfigured[d.Model] = true
+			unconfigured = append(unconfigured, d.Model)
+		}
+
+		row := map[string]any{
+			"tenant_id":                  tenantID,
+			"serial":                     d.Serial,
+			"manufacturer":               d.Manufacturer,
+			"model":                      d.Model,
+			"location":                   d.Location,
+			"agent_id":                   d.AgentID,
+			"agent_name":                 agentName[d.AgentID],
+			"duty_cycle":                 capacity.DutyCycle,
+			"recommended_monthly_volume": capacity.RecommendedMonthlyVolume,
+			"capacity_configured":        configured,
+		}
+		cd := &capacityDevice{
+			row:         row,
+			serial:      d.Serial,
+			agentID:     d.AgentID,
+			recommended: capacity.RecommendedMonthlyVolume,
+			status:      CapacityNoData,
+		}
+
+		monthly, observedDays, ok := g.monthlyVolume(ctx, d.Serial, start, end)
+		if ok {
+			cd.monthly = monthly
+			utilization := percentOf(monthly, capacity.RecommendedMonthlyVolume)
+			cd.status = opts.classifyUtilization(utilization)
+			row["monthly_volume"] = monthly
+			row["observed_days"] = observedDays
+			row["utilization_pct"] = utilization
+			if capacity.DutyCycle > 0 {
+				row["duty_cycle_pct"] = percentOf(monthly, capacity.DutyCycle)
+			}
+			totalMonthly += int64(monthly)
+			totalRecommended += int64(capacity.RecommendedMonthlyVolume)
+		}
+		row["status"] = cd.status
+		statusCounts[cd.status]++
+		working = append(working, cd)
+	}
+
+	recommendCapacityChanges(working)
+
+	rows := make([]map[string]any, 0, len(working))
+	for _, cd := range working {
+		rows = append(rows, cd.row)
+	}
+	// Devices furthest from their recommended volume first
+	sort.SliceStable(rows, func(i, j int) bool {
+		return capacityDeviation(rows[i]) > capacityDeviation(rows[j])
+	})
+	if params.Report.Limit > 0 && len(rows) > params.Report.Limit {
+		rows = rows[:params.Report.Limit]
+	}
+	sort.Strings(unconfigured)
+
+	columns := []string{
+		"tenant_id", "serial", "manufacturer", "model", "location", "agent_name",
+		"monthly_volume", "recommended_monthly_volume", "utilization_pct",
+		"duty_cycle", "duty_cycle_pct", "status", "recommendation",
+	}
+
+	return &GenerateResult{
+		Rows:     rows,
+		Columns:  columns,
+		RowCount: len(rows),
+		Summary: map[string]any{
+			"period_start":                start,
+			"period_end":                  end,
+			"total_devices":               len(working),
+			"underutilized_devices":       statusCounts[CapacityUnderutilized],
+			"ok_devices":                  statusCounts[CapacityOK],
+			"overutilized_devices":        statusCounts[CapacityOverutilized],
+			"devices_without_data":        statusCounts[CapacityNoData],
+			"fleet_monthly_volume":        totalMonthly,
+			"fleet_utilization_pct":       percentOf(int(totalMonthly), int(totalRecommended)),
+			"unconfigured_models":         unconfigured,
+			"underutilized_threshold_pct": opts.UnderutilizedPct,
+			"overutilized_threshold_pct":  opts.OverutilizedPct,
+		},
+		Metadata: map[string]string{
+			"report_type": string(params.Report.Type),
+			"generated":   time.Now().UTC().Format(time.RFC3339),
+		},
+	}, nil
+}
+
+// monthlyVolume estimates a device's monthly page volume from the counter
+// change over the period, normalized to 30 days. At least a day of history
+// is required for a meaningful estimate.
+func (g *Generator) monthlyVolume(ctx context.Context, serial string, start, end time.Time) (int, int, bool) {
+	latest, err := g.store.GetLatestMetrics(ctx, serial)
+	if err != nil || latest == nil {
+		return 0, 0, false
+	}
+	baseline, err := g.store.GetMetricsAtOrBefore(ctx, serial, start)
+	if err != nil || baseline == nil {
+		hist, err := g.store.GetMetricsHistory(ctx, serial, start)
+		if err != nil || len(hist) == 0 {
+			return 0, 0, false
+		}
+		baseline = hist[0]
+	}
+
+	observed := latest.Timestamp.Sub(baseline.Timestamp)
+	if latest.Timestamp.After(end) {
+		observed = end.Sub(baseline.Timestamp)
+	}
+	if observed < 24*time.Hour {
+		return 0, 0, false
+	}
+	pages := latest.PageCount - baseline.PageCount
+	if pages < 0 {
+		// Counter reset; the period can't be measured
+		return 0, 0, false
+	}
+	days := observed.Hours() / 24
+	monthly := int(math.Round(float64(pages) * daysPerMonth / days))
+	return monthly, int(math.Round(days)), true
+}
+
+// recommendCapacityChanges suggests redistribution for overloaded devices and
+// consolidation for idle ones. Devices are only paired within the same agent,
+// which stands in for a site.
+func recommendCapacityChanges(devices []*capacityDevice) {
+	byAgent := make(map[string][]*capacityDevice)
+	for _, cd := range devices {
+		byAgent[cd.agentID] = append(byAgent[cd.agentID], cd)
+	}
+
+	for _, cd := range devices {
+		util, _ := cd.row["utilization_pct"].(float64)
+		switch cd.status {
+		case CapacityOverutilized:
+			text := fmt.Sprintf("Runs at %.0f%% of recommended monthly volume", util)
+			if target := mostHeadroom(byAgent[cd.agentID], cd, cd.monthly-cd.recommended); target != nil {
+				text += fmt.Sprintf("; redistribute about %d pages/month to %s", cd.monthly-cd.recommended, target.serial)
+			} else {
+				text += "; consider a higher-capacity device"
+			}
+			cd.row["recommendation"] = text
+		case CapacityUnderutilized:
+			text := fmt.Sprintf("Prints %.0f%% of recommended monthly volume", util)
+			if target := mostHeadroom(byAgent[cd.agentID], cd, cd.monthly); target != nil {
+				text += fmt.Sprintf("; consolidate into %s", target.serial)
+			} else {
+				text += "; candidate for consolidation or removal"
+			}
+			cd.row["recommendation"] = text
+		case CapacityNoData:
+			cd.row["recommendation"] = "Not enough page count history to assess"
+		default:
+			cd.row["recommendation"] = ""
+		}
+	}
+}
+
+// mostHeadroom returns the healthy peer with the most spare capacity that can
+// absorb pages, or nil. Idle peers are skipped so load isn't moved onto
+// devices that are themselves consolidation candidates.
+func mostHeadroom(peers []*capacityDevice, self *capacityDevice, pages int) *capacityDevice {
+	var best *capacityDevice
+	for _, p := range peers {
+		if p == self || p.status != CapacityOK || p.headroom() < pages {
+			continue
+		}
+		if best == nil || p.headroom() > best.headroom() || (p.headroom() == best.headroom() && p.serial < best.serial) {
+			best = p
+		}
+	}
+	return best
+}
+
+// capacityDeviation ranks rows by how far utilization is from 100%; rows
+// without data sort last.
+func capacityDeviation(row map[string]any) float64 {
+	util, ok := row["utilization_pct"].(float64)
+	if !ok {
+		return -1
+	}
+	return math.Abs(math.Log((util + 1) / 101))
+}
+
+// percentOf returns part as a percentage of whole, rounded to one decimal.
+func percentOf(part, whole int) float64 {
+	if whole <= 0 {
+		return 0
+	}
+	return math.Round(float64(part)*1000/float64(whole)) / 10
+}
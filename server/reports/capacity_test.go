@@ -0,0 +1,116 @@
+package reports
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"printmaster/server/storage"
+)
+
+func TestGenerator_CapacityPlanning(t *testing.T) {
+	t.Parallel()
+
+	store := newMockGeneratorStore()
+	store.agents = []*storage.Agent{
+		{AgentID: "agent-1", Name: "HQ", TenantID: "tenant-a"},
+		{AgentID: "agent-2", Name: "Branch", TenantID: "tenant-b"},
+	}
+	store.devices = []*storage.Device{
+		newTestDevice("BUSY", "LaserJet M404", "10.0.0.1", "agent-1"),
+		newTestDevice("IDLE", "LaserJet M404", "10.0.0.2", "agent-1"),
+		newTestDevice("STEADY", "ImageRunner C3530", "10.0.0.3", "agent-1"),
+		newTestDevice("NEW", "ImageRunner C3530", "10.0.0.4", "agent-1"),
+		newTestDevice("OTHER", "LaserJet M404", "10.1.0.1", "agent-2"),
+	}
+
+	end := time.Now().UTC()
+	start := end.AddDate(0, 0, -30)
+	volumes := map[string]int{"BUSY": 8000, "IDLE": 200, "STEADY": 9000, "OTHER": 1000}
+	for serial, pages := range volumes {
+		base := &storage.MetricsSnapshot{Serial: serial, Timestamp: start.Add(-time.Hour), PageCount: 10000}
+		latest := &storage.MetricsSnapshot{Serial: serial, Timestamp: end.Add(-time.Hour), PageCount: 10000 + pages}
+		store.metricsHist[serial] = []*storage.MetricsSnapshot{base, latest}
+		store.metrics[serial] = latest
+	}
+	// Only a few hours of history: can't be assessed yet
+	store.metrics["NEW"] = &storage.MetricsSnapshot{Serial: "NEW", Timestamp: end, PageCount: 50}
+	store.metricsHist["NEW"] = []*storage.MetricsSnapshot{{Serial: "NEW", Timestamp: end.Add(-3 * time.Hour), PageCount: 10}}
+
+	gen := NewGenerator(store)
+	result, err := gen.Generate(context.Background(), GenerateParams{
+		Report: &storage.ReportDefinition{
+			Type:        storage.ReportTypeCapacityPlanning,
+			TenantIDs:   []string{"tenant-a"},
+			OptionsJSON: `{"models":{"laserjet m404":{"duty_cycle":80000,"recommended_monthly_volume":5000},"ImageRunner":{"recommended_monthly_volume":15000}}}`,
+		},
+		StartTime: start,
+		EndTime:   end,
+	})
+	if err != nil {
+		t.Fatalf("Generate failed: %v", err)
+	}
+
+	bySerial := make(map[string]map[string]any)
+	for _, row := range result.Rows {
+		bySerial[row["serial"].(string)] = row
+	}
+	if _, ok := bySerial["OTHER"]; ok {
+		t.Fatalf("tenant-b device should be excluded")
+	}
+	if len(result.Rows) != 4 {
+		t.Fatalf("expected 4 rows, got %d", len(result.Rows))
+	}
+
+	busy := bySerial["BUSY"]
+	if busy["status"] != CapacityOverutilized || busy["utilization_pct"] != 160.0 || busy["duty_cycle_pct"] != 10.0 {
+		t.Fatalf("unexpected busy row: %+v", busy)
+	}
+	if rec, _ := busy["recommendation"].(string); !strings.Contains(rec, "160%") || !strings.Contains(rec, "STEADY") {
+		t.Fatalf("expected redistribution to STEADY, got %q", rec)
+	}
+
+	idle := bySerial["IDLE"]
+	if idle["status"] != CapacityUnderutilized || idle["utilization_pct"] != 4.0 {
+		t.Fatalf("unexpected idle row: %+v", idle)
+	}
+	if rec, _ := idle["recommendation"].(string); !strings.Contains(rec, "4%") || !strings.Contains(rec, "consolidate into STEADY") {
+		t.Fatalf("expected consolidation into STEADY, got %q", rec)
+	}
+
+	if bySerial["STEADY"]["status"] != CapacityOK || bySerial["NEW"]["status"] != CapacityNoData {
+		t.Fatalf("unexpected statuses: steady=%v new=%v", bySerial["STEADY"]["status"], bySerial["NEW"]["status"])
+	}
+
+	// Furthest from recommended volume first, unassessed devices last
+	if result.Rows[0]["serial"] != "IDLE" || result.Rows[3]["serial"] != "NEW" {
+		t.Fatalf("unexpected order: %v, %v", result.Rows[0]["serial"], result.Rows[3]["serial"])
+	}
+	if result.Summary["overutilized_devices"] != 1 || result.Summary["underutilized_devices"] != 1 || result.Summary["devices_without_data"] != 1 {
+		t.Fatalf("unexpected summary: %+v", result.Summary)
+	}
+}
+
+func TestCapacityOptions_CapacityFor(t *testing.T) {
+	t.Parallel()
+
+	opts, err := ParseCapacityOptions(`{"models":{"M404":{"duty_cycle":80000},"LaserJet M404dn":{"recommended_monthly_volume":4000}},"default_recommended_monthly_volume":3000}`)
+	if err != nil {
+		t.Fatalf("ParseCapacityOptions: %v", err)
+	}
+
+	if c, ok := opts.CapacityFor("laserjet m404dn"); !ok || c.RecommendedMonthlyVolume != 4000 {
+		t.Errorf("exact match not preferred: %+v", c)
+	}
+	if c, ok := opts.CapacityFor("HP LaserJet M404n"); !ok || c.DutyCycle != 80000 || c.RecommendedMonthlyVolume != 3000 {
+		t.Errorf("substring match should use default recommended volume: %+v", c)
+	}
+	if c, ok := opts.CapacityFor("Unknown"); ok || c.RecommendedMonthlyVolume != 3000 {
+		t.Errorf("unknown model should fall back to default: %+v ok=%v", c, ok)
+	}
+
+	if _, err := ParseCapacityOptions(`{"models":[]}`); err == nil {
+		t.Errorf("expected error for malformed options")
+	}
+}
@@ -0,0 +1,61 @@
+package reports
+
+import (
+	"context"
+	"testing"
+
+	"printmaster/server/storage"
+)
+
+func TestGenerator_DataQuality(t *testing.T) {
+	t.Parallel()
+
+	store := newMockGeneratorStore()
+	hp1 := newTestDevice("SN1", "LaserJet M404", "10.0.0.1", "agent-1")
+	hp2 := newTestDevice("10.0.0.2", "", "10.0.0.2", "agent-1")
+	ricoh := newTestDevice("SN3", "MP C3004", "10.0.0.3", "agent-2")
+	for _, d := range []*storage.Device{hp1, hp2} {
+		d.Manufacturer = "HP"
+		d.RawData = map[string]interface{}{"sys_object_id": ".1.3.6.1.4.1.11.2.3.9.1"}
+	}
+	ricoh.Manufacturer = "Ricoh"
+	store.devices = []*storage.Device{hp1, hp2, ricoh}
+	store.metrics["SN1"] = &storage.MetricsSnapshot{PageCount: 1200, TonerLevels: map[string]interface{}{"black": 40}}
+	store.metrics["SN3"] = &storage.MetricsSnapshot{PageCount: 900}
+	store.samples = []*storage.ParseSample{
+		{AgentID: "agent-1", Manufacturer: "hp", SysObjectID: "1.3.6.1.4.1.11.2.3.9.1"},
+	}
+
+	gen := NewGenerator(store)
+	result, err := gen.Generate(context.Background(), GenerateParams{
+		Report: &storage.ReportDefinition{Type: storage.ReportTypeDataQuality},
+	})
+	if err != nil {
+		t.Fatalf("Generate: %v", err)
+	}
+	if result.RowCount != 2 {
+		t.Fatalf("expected 2 groups, got %d: %+v", result.RowCount, result.Rows)
+	}
+	hp := result.Rows[0]
+	if hp["manufacturer"] != "HP" || hp["sys_object_id"] != "1.3.6.1.4.1.11.2.3.9.1" {
+		t.Fatalf("unexpected first group: %+v", hp)
+	}
+	if hp["devices"] != 2 || hp["affected"] != 1 || hp["missing_serial"] != 1 || hp["missing_model"] != 1 ||
+		hp["missing_counters"] != 1 || hp["samples"] != 1 || hp["gap_rate"] != 50.0 {
+		t.Fatalf("unexpected HP counts: %+v", hp)
+	}
+	if r := result.Rows[1]; r["manufacturer"] != "Ricoh" || r["missing_toner"] != 1 || r["missing_counters"] != 0 {
+		t.Fatalf("unexpected Ricoh group: %+v", r)
+	}
+	if result.Summary["total_devices"] != 3 || result.Summary["affected_devices"] != 2 || result.Summary["samples"] != 1 {
+		t.Fatalf("unexpected summary: %+v", result.Summary)
+	}
+
+	// Agent filter drops devices and samples from other agents
+	result, err = gen.Generate(context.Background(), GenerateParams{
+		Report: &storage.ReportDefinition{Type: storage.ReportTypeDataQuality, AgentIDs: []string{"agent-2"}},
+	})
+	if err != nil || result.RowCount != 1 || result.Rows[0]["manufacturer"] != "Ricoh" || result.Summary["samples"] != 0 {
+		t.Fatalf("agent filter: %+v, %v", result, err)
+	}
+}
@@ -7,6 +7,8 @@ import (
 	"fmt"
 	"sort"
 	"time"
+
+	"printmaster/server/storage"
 )
 
 const tonerLevelsColumn = "toner_levels"
@@ -19,6 +21,23 @@ func NewFormatter() *Formatter {
 	return &Formatter{}
 }
 
+// Format formats the result in the report's configured format, falling back
+// to JSON for formats without a formatter.
+func (f *Formatter) Format(result *GenerateResult, report *storage.ReportDefinition) ([]byte, error) {
+	switch report.Format {
+	case storage.ReportFormatCSV:
+		return f.FormatCSV(result)
+	case storage.ReportFormatHTML:
+		return f.FormatHTML(result, report.Name)
+	case storage.ReportFormatXLSX:
+		return f.FormatXLSX(result)
+	case storage.ReportFormatPDF:
+		return f.FormatPDF(result, report.Name)
+	default:
+		return f.FormatJSON(result, true)
+	}
+}
+
 // FormatJSON formats the result as JSON.
 func (f *Formatter) FormatJSON(result *GenerateResult, pretty bool) ([]byte, error) {
 	output := map[string]interface{}{
@@ -53,23 +72,9 @@ func (f *Formatter) FormatCSV(result *GenerateResult) ([]byte, error) {
 	var buf bytes.Buffer
 	writer := csv.NewWriter(&buf)
 
-	// Expand certain structured columns (e.g., toner_levels) into native CSV columns.
-	rows := result.Rows
-	columns := result.Columns
-	rows, columns = expandMapColumn(rows, columns, tonerLevelsColumn, "toner_")
+	rows, columns := tabularRows(result)
 
 	// Write header
-	if len(columns) == 0 && len(rows) > 0 {
-		// Extract columns from first row
-		for key := range rows[0] {
-			columns = append(columns, key)
-		}
-		sort.Strings(columns) // Consistent ordering
-
-		// If columns were inferred, re-expand after inference as well.
-		rows, columns = expandMapColumn(rows, columns, tonerLevelsColumn, "toner_")
-	}
-
 	if err := writer.Write(columns); err != nil {
 		return nil, fmt.Errorf("write header: %w", err)
 	}
@@ -93,6 +98,25 @@ func (f *Formatter) FormatCSV(result *GenerateResult) ([]byte, error) {
 	return buf.Bytes(), nil
 }
 
+// tabularRows returns the result rows and columns for spreadsheet-style
+// output, with structured columns (e.g., toner_levels) expanded into native
+// columns and missing columns inferred from the first row.
+func tabularRows(result *GenerateResult) ([]map[string]any, []string) {
+	rows, columns := expandMapColumn(result.Rows, result.Columns, tonerLevelsColumn, "toner_")
+
+	if len(columns) == 0 && len(rows) > 0 {
+		// Extract columns from first row
+		for key := range rows[0] {
+			columns = append(columns, key)
+		}
+		sort.Strings(columns) // Consistent ordering
+
+		// If columns were inferred, re-expand after inference as well.
+		rows, columns = expandMapColumn(rows, columns, tonerLevelsColumn, "toner_")
+	}
+	return rows, columns
+}
+
 func expandMapColumn(rows []map[string]any, columns []string, columnName string, prefix string) ([]map[string]any, []string) {
 	if len(rows) == 0 {
 		return rows, columns
@@ -1,8 +1,14 @@
 package reports
 
 import (
+	"archive/zip"
+	"bytes"
+	"fmt"
+	"io"
 	"strings"
 	"testing"
+
+	"printmaster/server/storage"
 )
 
 func TestFormatter_FormatCSV(t *testing.T) {
@@ -227,3 +233,103 @@ func TestFormatter_FormatJSON_Pretty(t *testing.T) {
 		t.Error("Pretty JSON should have indentation")
 	}
 }
+
+func TestFormatter_FormatXLSX(t *testing.T) {
+	t.Parallel()
+
+	f := NewFormatter()
+	result := &GenerateResult{
+		Columns: []string{"serial", "page_count"},
+		Rows: []map[string]any{
+			{"serial": "SN<1>", "page_count": 1200},
+		},
+	}
+
+	data, err := f.FormatXLSX(result)
+	if err != nil {
+		t.Fatalf("FormatXLSX failed: %v", err)
+	}
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatalf("output is not a zip archive: %v", err)
+	}
+	var sheet string
+	for _, file := range zr.File {
+		if file.Name != "xl/worksheets/sheet1.xml" {
+			continue
+		}
+		rc, err := file.Open()
+		if err != nil {
+			t.Fatalf("open sheet: %v", err)
+		}
+		body, _ := io.ReadAll(rc)
+		rc.Close()
+		sheet = string(body)
+	}
+	if sheet == "" {
+		t.Fatal("worksheet missing from workbook")
+	}
+	for _, want := range []string{
+		`<c r="A1" t="inlineStr"><is><t xml:space="preserve">serial</t></is></c>`,
+		`<t xml:space="preserve">SN&lt;1&gt;</t>`,
+		`<c r="B2"><v>1200</v></c>`,
+	} {
+		if !strings.Contains(sheet, want) {
+			t.Errorf("worksheet missing %s:\n%s", want, sheet)
+		}
+	}
+
+	encoded := EncodeResultData(storage.ReportFormatXLSX, data)
+	decoded, err := DecodeResultData(storage.ReportFormatXLSX, encoded)
+	if err != nil || !bytes.Equal(decoded, data) {
+		t.Fatalf("result data round trip failed: %v", err)
+	}
+}
+
+func TestFormatter_FormatPDF(t *testing.T) {
+	t.Parallel()
+
+	result := &GenerateResult{
+		Columns:  []string{"serial", "uptime_pct"},
+		Summary:  map[string]any{"devices": 120},
+		Metadata: map[string]string{"generated": "2026-10-01T00:00:00Z"},
+	}
+	for i := range 120 {
+		result.Rows = append(result.Rows, map[string]any{"serial": fmt.Sprintf("SN%03d", i), "uptime_pct": 99.5})
+	}
+
+	data, err := NewFormatter().FormatPDF(result, "Availability (SLA)")
+	if err != nil {
+		t.Fatalf("FormatPDF failed: %v", err)
+	}
+	doc := string(data)
+	if !strings.HasPrefix(doc, "%PDF-") || !strings.HasSuffix(strings.TrimSpace(doc), "%%EOF") {
+		t.Fatalf("output is not a PDF document")
+	}
+	for _, want := range []string{`(Availability \(SLA\))`, "(Generated: 2026-10-01T00:00:00Z)", "(Devices: 120)", "(SN119)"} {
+		if !strings.Contains(doc, want) {
+			t.Errorf("PDF missing %s", want)
+		}
+	}
+	// 120 rows don't fit on one page; the header is repeated on each page
+	if pages := strings.Count(doc, "/Type /Page "); pages < 3 {
+		t.Errorf("expected the table to span several pages, got %d", pages)
+	}
+	if strings.Count(doc, "(Serial)") != strings.Count(doc, "/Type /Page ") {
+		t.Error("table header should be repeated on every page")
+	}
+
+	if _, err := NewFormatter().FormatPDF(&GenerateResult{}, "empty"); err == nil {
+		t.Error("expected an error for an empty result")
+	}
+}
+
+func TestXLSXColumnName(t *testing.T) {
+	t.Parallel()
+
+	for i, want := range map[int]string{0: "A", 25: "Z", 26: "AA", 27: "AB", 701: "ZZ", 702: "AAA"} {
+		if got := xlsxColumnName(i); got != want {
+			t.Errorf("xlsxColumnName(%d) = %s, want %s", i, got, want)
+		}
+	}
+}
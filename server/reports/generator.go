@@ -4,7 +4,9 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"printmaster/server/devicestatus"
 	"printmaster/server/storage"
+	"slices"
 	"sort"
 	"strings"
 	"time"
@@ -30,6 +32,15 @@ type GeneratorStore interface {
 	// Alerts
 	ListAlerts(ctx context.Context, filter storage.AlertFilter) ([]*storage.Alert, error)
 	GetAlertSummary(ctx context.Context) (*storage.AlertSummary, error)
+
+	// Cartridge baseline library
+	ListYieldBaselines(ctx context.Context) ([]storage.YieldBaseline, error)
+
+	// Device notes and maintenance log
+	ListDeviceNotes(ctx context.Context, filter storage.DeviceNoteFilter) ([]*storage.DeviceNote, error)
+
+	// Parse-debug samples uploaded by agents
+	ListParseSamples(ctx context.Context, filter storage.ParseSampleFilter) ([]*storage.ParseSample, error)
 }
 
 // Generator generates reports from stored data.
@@ -108,6 +119,38 @@ func (g *Generator) Generate(ctx context.Context, params GenerateParams) (*Gener
 	case storage.ReportTypeAlertHistory:
 		return g.generateAlertHistory(ctx, params)
 
+	// Security reports
+	case storage.ReportTypeSecurityPosture:
+		return g.generateSecurityPosture(ctx, params)
+
+	// Capacity planning
+	case storage.ReportTypeCapacityPlanning:
+		return g.generateCapacityPlanning(ctx, params)
+
+	// Toner yield against the baseline library
+	case storage.ReportTypeTonerYield:
+		return g.generateTonerYield(ctx, params)
+
+	// Notes and maintenance entries written by technicians
+	case storage.ReportTypeMaintenanceLog:
+		return g.generateMaintenanceLog(ctx, params)
+
+	// Fleet-wide parsing gaps by manufacturer and sysObjectID
+	case storage.ReportTypeDataQuality:
+		return g.generateDataQuality(ctx, params)
+
+	// Paper, energy and CO2 estimates from page volumes
+	case storage.ReportTypeSustainability:
+		return g.generateSustainability(ctx, params)
+
+	// Uptime against an SLA target from offline alerts
+	case storage.ReportTypeAvailability:
+		return g.generateAvailability(ctx, params)
+
+	// Report builder
+	case storage.ReportTypeCustom:
+		return g.generateCustom(ctx, params)
+
 	default:
 		return nil, fmt.Errorf("unsupported report type: %s", report.Type)
 	}
@@ -122,7 +165,9 @@ func (g *Generator) generateDeviceInventory(ctx context.Context, params Generate
 	}
 
 	// Apply filters if specified
-	devices = g.filterDevices(devices, params.Report)
+	if devices, err = g.filterDevices(ctx, devices, params.Report); err != nil {
+		return nil, err
+	}
 
 	rows := make([]map[string]any, 0, len(devices))
 	for _, d := range devices {
@@ -290,7 +335,9 @@ func (g *Generator) generateUsageSummary(ctx context.Context, params GeneratePar
 		return nil, fmt.Errorf("list devices: %w", err)
 	}
 
-	devices = g.filterDevices(devices, params.Report)
+	if devices, err = g.filterDevices(ctx, devices, params.Report); err != nil {
+		return nil, err
+	}
 
 	includeDeltas := params.Report != nil && params.Report.TimeRangeType != "current"
 
@@ -435,7 +482,9 @@ func (g *Generator) generateUsageByDevice(ctx context.Context, params GeneratePa
 		return nil, fmt.Errorf("list devices: %w", err)
 	}
 
-	devices = g.filterDevices(devices, params.Report)
+	if devices, err = g.filterDevices(ctx, devices, params.Report); err != nil {
+		return nil, err
+	}
 
 	rows := make([]map[string]any, 0, len(devices))
 	var totalPages int64
@@ -510,7 +559,9 @@ func (g *Generator) generateSuppliesStatus(ctx context.Context, params GenerateP
 		return nil, fmt.Errorf("list devices: %w", err)
 	}
 
-	devices = g.filterDevices(devices, params.Report)
+	if devices, err = g.filterDevices(ctx, devices, params.Report); err != nil {
+		return nil, err
+	}
 
 	rows := make([]map[string]any, 0, len(devices))
 	var criticalCount, lowCount, okCount int
@@ -669,15 +720,12 @@ func (g *Generator) generateHealthSummary(ctx context.Context, params GeneratePa
 			onlineDevices++
 		}
 
-		// Check status messages for errors/warnings
-		for _, msg := range d.StatusMessages {
-			if containsAny(msg, []string{"error", "jam", "failure"}) {
-				errorDevices++
-				break
-			} else if containsAny(msg, []string{"warning", "low"}) {
-				warningDevices++
-				break
-			}
+		// Classify status messages through the shared device status rules
+		switch devicestatus.Active().Classify(d.Manufacturer, d.StatusMessages).State {
+		case devicestatus.StateError, devicestatus.StateOffline:
+			errorDevices++
+		case devicestatus.StateWarning:
+			warningDevices++
 		}
 	}
 
@@ -775,11 +823,13 @@ func (g *Generator) generateErrorDevices(ctx context.Context, params GeneratePar
 		return nil, fmt.Errorf("list devices: %w", err)
 	}
 
+	normalizer := devicestatus.Active()
 	var rows []map[string]any
 	for _, d := range devices {
 		var errors []string
 		for _, msg := range d.StatusMessages {
-			if containsAny(msg, []string{"error", "jam", "failure", "warning"}) {
+			state, _, ok := normalizer.ClassifyMessage(d.Manufacturer, msg)
+			if ok && state.Severity() >= devicestatus.StateWarning.Severity() {
 				errors = append(errors, msg)
 			}
 		}
@@ -792,6 +842,7 @@ func (g *Generator) generateErrorDevices(ctx context.Context, params GeneratePar
 				"ip":           d.IP,
 				"location":     d.Location,
 				"agent_id":     d.AgentID,
+				"state":        string(normalizer.Classify(d.Manufacturer, d.StatusMessages).State),
 				"errors":       errors,
 				"error_count":  len(errors),
 				"last_seen":    d.LastSeen,
@@ -802,7 +853,7 @@ func (g *Generator) generateErrorDevices(ctx context.Context, params GeneratePar
 
 	columns := []string{
 		"serial", "model", "manufacturer", "ip", "location",
-		"agent_id", "errors", "error_count", "last_seen",
+		"agent_id", "state", "errors", "error_count", "last_seen",
 	}
 
 	return &GenerateResult{
@@ -988,44 +1039,58 @@ func (g *Generator) generateAlertHistory(ctx context.Context, params GeneratePar
 
 // ---------- Helper Functions ----------
 
-func (g *Generator) filterDevices(devices []*storage.Device, report *storage.ReportDefinition) []*storage.Device {
+// filterDevices limits devices to the report's agents, tenants and sites.
+// Devices belong to the tenant and sites of the agent that reports them.
+func (g *Generator) filterDevices(ctx context.Context, devices []*storage.Device, report *storage.ReportDefinition) ([]*storage.Device, error) {
 	if len(report.TenantIDs) == 0 && len(report.SiteIDs) == 0 && len(report.AgentIDs) == 0 {
-		return devices
+		return devices, nil
 	}
 
-	// Build lookup maps
-	agentFilter := make(map[string]bool)
-	for _, id := range report.AgentIDs {
-		agentFilter[id] = true
+	allowed := make(map[string]bool)
+	if len(report.TenantIDs) == 0 && len(report.SiteIDs) == 0 {
+		for _, id := range report.AgentIDs {
+			allowed[id] = true
+		}
+	} else {
+		agents, err := g.store.ListAgents(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("list agents: %w", err)
+		}
+		for _, a := range g.filterAgents(agents, report) {
+			allowed[a.AgentID] = true
+		}
 	}
 
 	var filtered []*storage.Device
 	for _, d := range devices {
-		// Filter by agent if specified
-		if len(agentFilter) > 0 && !agentFilter[d.AgentID] {
-			continue
+		if d != nil && allowed[d.AgentID] {
+			filtered = append(filtered, d)
 		}
-		// TODO: Add tenant/site filtering when device has those fields
-		filtered = append(filtered, d)
 	}
-	return filtered
+	return filtered, nil
 }
 
+// filterAgents limits agents to the report's agents, tenants and sites.
 func (g *Generator) filterAgents(agents []*storage.Agent, report *storage.ReportDefinition) []*storage.Agent {
-	if len(report.AgentIDs) == 0 {
+	if len(report.TenantIDs) == 0 && len(report.SiteIDs) == 0 && len(report.AgentIDs) == 0 {
 		return agents
 	}
 
-	agentFilter := make(map[string]bool)
-	for _, id := range report.AgentIDs {
-		agentFilter[id] = true
-	}
-
 	var filtered []*storage.Agent
 	for _, a := range agents {
-		if agentFilter[a.AgentID] {
-			filtered = append(filtered, a)
+		if a == nil {
+			continue
+		}
+		if len(report.AgentIDs) > 0 && !slices.Contains(report.AgentIDs, a.AgentID) {
+			continue
+		}
+		if len(report.TenantIDs) > 0 && !slices.Contains(report.TenantIDs, a.TenantID) {
+			continue
+		}
+		if len(report.SiteIDs) > 0 && !slices.ContainsFunc(a.SiteIDs, func(id string) bool { return slices.Contains(report.SiteIDs, id) }) {
+			continue
 		}
+		filtered = append(filtered, a)
 	}
 	return filtered
 }
@@ -1045,33 +1110,6 @@ func toInt(v interface{}) int {
 	}
 }
 
-func containsAny(s string, substrs []string) bool {
-	lower := s
-	for _, sub := range substrs {
-		if len(s) > 0 && len(sub) > 0 {
-			// Simple case-insensitive contains
-			if contains(lower, sub) {
-				return true
-			}
-		}
-	}
-	return false
-}
-
-func contains(s, substr string) bool {
-	return len(s) >= len(substr) && (s == substr || len(substr) == 0 ||
-		(len(s) > 0 && len(substr) > 0 && findSubstring(s, substr)))
-}
-
-func findSubstring(s, substr string) bool {
-	for i := 0; i <= len(s)-len(substr); i++ {
-		if s[i:i+len(substr)] == substr {
-			return true
-		}
-	}
-	return false
-}
-
 func calculateHealthScore(online, total, errors int) float64 {
 	if total == 0 {
 		return 100.0
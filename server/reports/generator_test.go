@@ -19,6 +19,9 @@ type mockGeneratorStore struct {
 	sites        map[string][]*storage.Site
 	alerts       []*storage.Alert
 	alertSummary *storage.AlertSummary
+	baselines    []storage.YieldBaseline
+	notes        []*storage.DeviceNote
+	samples      []*storage.ParseSample
 }
 
 func newMockGeneratorStore() *mockGeneratorStore {
@@ -116,6 +119,28 @@ func (m *mockGeneratorStore) GetAlertSummary(ctx context.Context) (*storage.Aler
 	return m.alertSummary, nil
 }
 
+func (m *mockGeneratorStore) ListYieldBaselines(ctx context.Context) ([]storage.YieldBaseline, error) {
+	return m.baselines, nil
+}
+
+func (m *mockGeneratorStore) ListDeviceNotes(ctx context.Context, filter storage.DeviceNoteFilter) ([]*storage.DeviceNote, error) {
+	var out []*storage.DeviceNote
+	for _, n := range m.notes {
+		if !filter.Since.IsZero() && n.OccurredAt.Before(filter.Since) {
+			continue
+		}
+		if !filter.Until.IsZero() && !n.OccurredAt.Before(filter.Until) {
+			continue
+		}
+		out = append(out, n)
+	}
+	return out, nil
+}
+
+func (m *mockGeneratorStore) ListParseSamples(ctx context.Context, filter storage.ParseSampleFilter) ([]*storage.ParseSample, error) {
+	return m.samples, nil
+}
+
 // helper to create a test device
 func newTestDevice(serial, model, ip string, agentID string) *storage.Device {
 	return &storage.Device{
@@ -459,6 +484,24 @@ func TestGenerator_WithFilters(t *testing.T) {
 	if len(result.Rows) != 2 {
 		t.Errorf("expected 2 rows for agent-1, got %d", len(result.Rows))
 	}
+
+	// Filter by tenant through the devices' agents
+	store.agents = []*storage.Agent{
+		{AgentID: "agent-1", TenantID: "tenant-a"},
+		{AgentID: "agent-2", TenantID: "tenant-b"},
+	}
+	result, err = gen.Generate(context.Background(), GenerateParams{
+		Report: &storage.ReportDefinition{
+			Type:      storage.ReportTypeDeviceInventory,
+			TenantIDs: []string{"tenant-b"},
+		},
+	})
+	if err != nil {
+		t.Fatalf("Generate failed: %v", err)
+	}
+	if len(result.Rows) != 1 || result.Rows[0]["serial"] != "SN002" {
+		t.Errorf("expected only the tenant-b device, got %+v", result.Rows)
+	}
 }
 
 func TestGenerator_InvalidReportType(t *testing.T) {
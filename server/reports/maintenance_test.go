@@ -0,0 +1,57 @@
+package reports
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"printmaster/server/storage"
+)
+
+func TestGenerator_MaintenanceLog(t *testing.T) {
+	t.Parallel()
+
+	store := newMockGeneratorStore()
+	store.devices = []*storage.Device{
+		newTestDevice("SN1", "LaserJet M404", "10.0.0.1", "agent-1"),
+		newTestDevice("SN2", "ImageRunner C3530", "10.0.0.2", "agent-2"),
+	}
+	end := time.Now().UTC()
+	start := end.AddDate(0, 0, -30)
+	pages := int64(52000)
+	store.notes = []*storage.DeviceNote{
+		{Serial: "SN1", Kind: storage.DeviceNoteKindPartReplaced, Part: "Fuser", PageCount: &pages, Author: "tech", OccurredAt: end.Add(-time.Hour)},
+		{Serial: "SN2", Kind: storage.DeviceNoteKindServiceVisit, Vendor: "Acme Copiers", Author: "tech", OccurredAt: end.Add(-48 * time.Hour)},
+		{Serial: "SN1", Kind: storage.DeviceNoteKindNote, Body: "Before the period", Author: "alice", OccurredAt: start.Add(-time.Hour)},
+	}
+
+	gen := NewGenerator(store)
+	result, err := gen.Generate(context.Background(), GenerateParams{
+		Report:    &storage.ReportDefinition{Type: storage.ReportTypeMaintenanceLog},
+		StartTime: start,
+		EndTime:   end,
+	})
+	if err != nil {
+		t.Fatalf("Generate: %v", err)
+	}
+	if result.RowCount != 2 {
+		t.Fatalf("expected 2 entries in period, got %d", result.RowCount)
+	}
+	first := result.Rows[0]
+	if first["summary"] != "Replaced Fuser" || first["model"] != "LaserJet M404" || first["page_count"] != pages {
+		t.Fatalf("unexpected first row: %+v", first)
+	}
+	if result.Summary["parts_replaced"] != 1 || result.Summary["service_visits"] != 1 || result.Summary["devices"] != 2 {
+		t.Fatalf("unexpected summary: %+v", result.Summary)
+	}
+
+	// Agent filter drops entries on devices reported by other agents
+	result, err = gen.Generate(context.Background(), GenerateParams{
+		Report:    &storage.ReportDefinition{Type: storage.ReportTypeMaintenanceLog, AgentIDs: []string{"agent-2"}},
+		StartTime: start,
+		EndTime:   end,
+	})
+	if err != nil || result.RowCount != 1 || result.Rows[0]["serial"] != "SN2" {
+		t.Fatalf("agent filter: %+v, %v", result, err)
+	}
+}
@@ -0,0 +1,145 @@
+package reports
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+
+	"printmaster/server/internal/pdf"
+)
+
+// PDF page layout in points: US Letter landscape, so wide tables stay legible.
+const (
+	pdfPageWidth  = 792.0
+	pdfPageHeight = 612.0
+	pdfMargin     = 36.0
+	pdfTitleSize  = 14.0
+	pdfTextSize   = 8.0
+	pdfCellSize   = 7.0
+	pdfRowHeight  = 12.0
+	pdfCellPad    = 3.0
+)
+
+// FormatPDF formats the result as a printable table with the title, the
+// generation time and the summary on the first page. Columns match the CSV
+// output; columns are sized to their content and text that doesn't fit is
+// cut with an ellipsis.
+func (f *Formatter) FormatPDF(result *GenerateResult, title string) ([]byte, error) {
+	rows, columns := tabularRows(result)
+	if len(rows) == 0 && len(result.Summary) == 0 {
+		return nil, fmt.Errorf("no data to format")
+	}
+
+	doc := pdf.NewWriter()
+	page := &bytes.Buffer{}
+	y := pdfPageHeight - pdfMargin - pdfTitleSize
+	pdfText(page, pdfMargin, y, pdfTitleSize, pdf.FitText(title, pdfTitleSize, pdfPageWidth-2*pdfMargin))
+	y -= pdfTitleSize
+	if gen := result.Metadata["generated"]; gen != "" {
+		pdfText(page, pdfMargin, y, pdfTextSize, "Generated: "+gen)
+		y -= pdfRowHeight
+	}
+
+	if len(result.Summary) > 0 {
+		keys := make([]string, 0, len(result.Summary))
+		for k := range result.Summary {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		y -= pdfTextSize / 2
+		x := pdfMargin
+		for _, k := range keys {
+			item := formatLabel(k) + ": " + formatValue(result.Summary[k])
+			w := pdf.TextWidth(item, pdfTextSize) + 18
+			if x > pdfMargin && x+w > pdfPageWidth-pdfMargin {
+				x = pdfMargin
+				y -= pdfRowHeight
+			}
+			pdfText(page, x, y, pdfTextSize, pdf.FitText(item, pdfTextSize, pdfPageWidth-2*pdfMargin))
+			x += w
+		}
+		y -= pdfRowHeight
+	}
+	y -= pdfRowHeight / 2
+
+	if len(columns) > 0 {
+		header := make([]string, len(columns))
+		for i, col := range columns {
+			header[i] = formatLabel(col)
+		}
+		cells := make([][]string, len(rows))
+		for i, row := range rows {
+			cells[i] = make([]string, len(columns))
+			for j, col := range columns {
+				cells[i][j] = formatValue(row[col])
+			}
+		}
+		widths := pdfColumnWidths(header, cells, pdfPageWidth-2*pdfMargin)
+
+		drawHeader := func() {
+			y -= pdfRowHeight
+			fmt.Fprintf(page, "q 0.85 g %s %s %s %s re f Q\n",
+				pdf.Num(pdfMargin), pdf.Num(y), pdf.Num(pdfPageWidth-2*pdfMargin), pdf.Num(pdfRowHeight))
+			pdfRow(page, y, widths, header)
+		}
+		drawHeader()
+		for i, record := range cells {
+			if y-pdfRowHeight < pdfMargin {
+				doc.AddPage(pdfPageWidth, pdfPageHeight, page.Bytes())
+				page = &bytes.Buffer{}
+				y = pdfPageHeight - pdfMargin
+				drawHeader()
+			}
+			y -= pdfRowHeight
+			if i%2 == 1 {
+				fmt.Fprintf(page, "q 0.96 g %s %s %s %s re f Q\n",
+					pdf.Num(pdfMargin), pdf.Num(y), pdf.Num(pdfPageWidth-2*pdfMargin), pdf.Num(pdfRowHeight))
+			}
+			pdfRow(page, y, widths, record)
+		}
+	}
+	doc.AddPage(pdfPageWidth, pdfPageHeight, page.Bytes())
+
+	var buf bytes.Buffer
+	if err := doc.Render(&buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// pdfColumnWidths sizes columns to their widest cell (header or one of the
+// first 200 rows), capped at a third of the page and scaled down together
+// when the table is wider than width.
+func pdfColumnWidths(header []string, cells [][]string, width float64) []float64 {
+	widths := make([]float64, len(header))
+	total := 0.0
+	for i, h := range header {
+		w := pdf.TextWidth(h, pdfCellSize)
+		for r := 0; r < len(cells) && r < 200; r++ {
+			w = max(w, pdf.TextWidth(cells[r][i], pdfCellSize))
+		}
+		widths[i] = min(w, width/3) + 2*pdfCellPad
+		total += widths[i]
+	}
+	if total > width {
+		for i := range widths {
+			widths[i] *= width / total
+		}
+	}
+	return widths
+}
+
+func pdfRow(page *bytes.Buffer, y float64, widths []float64, values []string) {
+	x := pdfMargin
+	for i, v := range values {
+		pdfText(page, x+pdfCellPad, y+(pdfRowHeight-pdfCellSize)/2+1, pdfCellSize, pdf.FitText(v, pdfCellSize, widths[i]-2*pdfCellPad))
+		x += widths[i]
+	}
+}
+
+func pdfText(page *bytes.Buffer, x, y, size float64, s string) {
+	if s == "" {
+		return
+	}
+	fmt.Fprintf(page, "BT /F1 %s Tf %s %s Td %s Tj ET\n", pdf.Num(size), pdf.Num(x), pdf.Num(y), pdf.String(s))
+}
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"printmaster/common/logger"
 	"printmaster/server/storage"
+	"strings"
 	"sync"
 	"time"
 )
@@ -32,6 +33,8 @@ type Scheduler struct {
 	formatter *Formatter
 	logger    *logger.Logger
 
+	delivery Delivery
+
 	interval time.Duration
 	stopCh   chan struct{}
 	wg       sync.WaitGroup
@@ -51,6 +54,18 @@ func NewScheduler(store SchedulerStore, log *logger.Logger) *Scheduler {
 	}
 }
 
+// Delivery sends a finished report to the report's email recipients. data
+// is the report formatted as report.Format.
+type Delivery func(ctx context.Context, report *storage.ReportDefinition, run *storage.ReportRun, data []byte) error
+
+// SetDelivery sets how scheduled reports reach their recipients. Without a
+// delivery the reports are only kept in the run history.
+func (s *Scheduler) SetDelivery(d Delivery) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.delivery = d
+}
+
 // SetInterval sets the check interval for due schedules.
 func (s *Scheduler) SetInterval(d time.Duration) {
 	s.mu.Lock()
@@ -121,7 +136,7 @@ func (s *Scheduler) checkAndRun() {
 	}
 
 	for _, schedule := range schedules {
-		if err := s.runSchedule(ctx, schedule); err != nil {
+		if _, err := s.RunSchedule(ctx, schedule); err != nil {
 			s.logger.Error("Failed to run scheduled report",
 				"schedule_id", schedule.ID,
 				"report_id", schedule.ReportID,
@@ -130,14 +145,16 @@ func (s *Scheduler) checkAndRun() {
 	}
 }
 
-func (s *Scheduler) runSchedule(ctx context.Context, schedule *storage.ReportSchedule) error {
+// RunSchedule generates a schedule's report, stores the run, emails it to the
+// report's recipients and moves the schedule to its next run.
+func (s *Scheduler) RunSchedule(ctx context.Context, schedule *storage.ReportSchedule) (*storage.ReportRun, error) {
 	// Get the report definition
 	report, err := s.store.GetReport(ctx, schedule.ReportID)
 	if err != nil {
-		return fmt.Errorf("get report: %w", err)
+		return nil, fmt.Errorf("get report: %w", err)
 	}
 	if report == nil {
-		return fmt.Errorf("report %d not found", schedule.ReportID)
+		return nil, fmt.Errorf("report %d not found", schedule.ReportID)
 	}
 
 	// Create a run record
@@ -151,7 +168,7 @@ func (s *Scheduler) runSchedule(ctx context.Context, schedule *storage.ReportSch
 	}
 
 	if err := s.store.CreateReportRun(ctx, run); err != nil {
-		return fmt.Errorf("create run: %w", err)
+		return nil, fmt.Errorf("create run: %w", err)
 	}
 
 	// Calculate time range
@@ -178,21 +195,11 @@ func (s *Scheduler) runSchedule(ctx context.Context, schedule *storage.ReportSch
 		nextRun := s.calculateNextRun(schedule)
 		s.store.UpdateScheduleAfterRun(ctx, schedule.ID, run.ID, nextRun, true)
 
-		return fmt.Errorf("generate report: %w", err)
+		return run, fmt.Errorf("generate report: %w", err)
 	}
 
 	// Format the result
-	var data []byte
-	switch report.Format {
-	case storage.ReportFormatJSON:
-		data, err = s.formatter.FormatJSON(result, true)
-	case storage.ReportFormatCSV:
-		data, err = s.formatter.FormatCSV(result)
-	case storage.ReportFormatHTML:
-		data, err = s.formatter.FormatHTML(result, report.Name)
-	default:
-		data, err = s.formatter.FormatJSON(result, true)
-	}
+	data, err := s.formatter.Format(result, report)
 
 	if err != nil {
 		now := time.Now()
@@ -205,7 +212,7 @@ func (s *Scheduler) runSchedule(ctx context.Context, schedule *storage.ReportSch
 		nextRun := s.calculateNextRun(schedule)
 		s.store.UpdateScheduleAfterRun(ctx, schedule.ID, run.ID, nextRun, true)
 
-		return fmt.Errorf("format report: %w", err)
+		return run, fmt.Errorf("format report: %w", err)
 	}
 
 	// Update run with results
@@ -215,16 +222,16 @@ func (s *Scheduler) runSchedule(ctx context.Context, schedule *storage.ReportSch
 	run.DurationMS = now.Sub(run.StartedAt).Milliseconds()
 	run.RowCount = result.RowCount
 	run.ResultSize = int64(len(data))
-	run.ResultData = string(data) // Store inline for now
+	run.ResultData = EncodeResultData(report.Format, data) // Store inline for now
 
 	if err := s.store.UpdateReportRun(ctx, run); err != nil {
-		return fmt.Errorf("update run: %w", err)
+		return run, fmt.Errorf("update run: %w", err)
 	}
 
 	// Update schedule
 	nextRun := s.calculateNextRun(schedule)
 	if err := s.store.UpdateScheduleAfterRun(ctx, schedule.ID, run.ID, nextRun, false); err != nil {
-		return fmt.Errorf("update schedule: %w", err)
+		return run, fmt.Errorf("update schedule: %w", err)
 	}
 
 	s.logger.Info("Scheduled report completed",
@@ -235,10 +242,43 @@ func (s *Scheduler) runSchedule(ctx context.Context, schedule *storage.ReportSch
 		"row_count", result.RowCount,
 		"duration_ms", run.DurationMS)
 
-	// TODO: Send notifications (email, webhook) if configured
-	// This would use the report.EmailRecipients and report.WebhookURL
+	s.deliver(ctx, report, run, data)
+	return run, nil
+}
 
-	return nil
+// deliver emails a completed run and records the outcome on the run. A
+// failed delivery doesn't fail the run: the report stays downloadable.
+func (s *Scheduler) deliver(ctx context.Context, report *storage.ReportDefinition, run *storage.ReportRun, data []byte) {
+	s.mu.Lock()
+	delivery := s.delivery
+	s.mu.Unlock()
+
+	var recipients []string
+	for _, r := range report.EmailRecipients {
+		if r = strings.TrimSpace(r); r != "" {
+			recipients = append(recipients, r)
+		}
+	}
+	if delivery == nil || len(recipients) == 0 {
+		return
+	}
+
+	delivered := *report
+	delivered.EmailRecipients = recipients
+	if err := delivery(ctx, &delivered, run, data); err != nil {
+		run.DeliveryError = err.Error()
+		s.logger.Warn("Failed to email scheduled report",
+			"report_id", report.ID,
+			"run_id", run.ID,
+			"error", err)
+	} else {
+		now := time.Now()
+		run.DeliveredTo = recipients
+		run.DeliveredAt = &now
+	}
+	if err := s.store.UpdateReportRun(ctx, run); err != nil {
+		s.logger.Error("Failed to record report delivery", "run_id", run.ID, "error", err)
+	}
 }
 
 func (s *Scheduler) calculateTimeRange(report *storage.ReportDefinition) (time.Time, time.Time) {
@@ -256,6 +296,10 @@ func (s *Scheduler) calculateTimeRange(report *storage.ReportDefinition) (time.T
 		return now.Add(-30 * 24 * time.Hour), endTime
 	case "last_90d":
 		return now.Add(-90 * 24 * time.Hour), endTime
+	case "last_month":
+		// The previous calendar month, for monthly page count and SLA reports
+		thisMonth := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+		return thisMonth.AddDate(0, -1, 0), thisMonth
 	case "custom":
 		if report.TimeRangeDays > 0 {
 			return now.Add(-time.Duration(report.TimeRangeDays) * 24 * time.Hour), endTime
@@ -369,17 +413,7 @@ func (s *Scheduler) RunNow(ctx context.Context, reportID int64, runBy string) (*
 	}
 
 	// Format
-	var data []byte
-	switch report.Format {
-	case storage.ReportFormatJSON:
-		data, err = s.formatter.FormatJSON(result, true)
-	case storage.ReportFormatCSV:
-		data, err = s.formatter.FormatCSV(result)
-	case storage.ReportFormatHTML:
-		data, err = s.formatter.FormatHTML(result, report.Name)
-	default:
-		data, err = s.formatter.FormatJSON(result, true)
-	}
+	data, err := s.formatter.Format(result, report)
 
 	if err != nil {
 		now := time.Now()
@@ -398,7 +432,7 @@ func (s *Scheduler) RunNow(ctx context.Context, reportID int64, runBy string) (*
 	run.DurationMS = now.Sub(run.StartedAt).Milliseconds()
 	run.RowCount = result.RowCount
 	run.ResultSize = int64(len(data))
-	run.ResultData = string(data)
+	run.ResultData = EncodeResultData(report.Format, data)
 
 	if err := s.store.UpdateReportRun(ctx, run); err != nil {
 		return run, fmt.Errorf("update run: %w", err)
@@ -382,6 +382,14 @@ func TestScheduler_CalculateTimeRange(t *testing.T) {
 				return diff >= 89*24*time.Hour && diff <= 91*24*time.Hour
 			},
 		},
+		{
+			name:          "last_month",
+			timeRangeType: "last_month",
+			checkStart: func(start, end time.Time) bool {
+				return start.Day() == 1 && end.Day() == 1 && start.AddDate(0, 1, 0).Equal(end) &&
+					start.Hour() == 0 && !end.After(time.Now())
+			},
+		},
 		{
 			name:          "custom_days",
 			timeRangeType: "custom",
@@ -583,6 +591,7 @@ func TestScheduler_RunNow_AllFormats(t *testing.T) {
 		storage.ReportFormatJSON,
 		storage.ReportFormatCSV,
 		storage.ReportFormatHTML,
+		storage.ReportFormatPDF,
 	}
 
 	for _, format := range formats {
@@ -685,3 +694,64 @@ func TestScheduler_UnknownFrequency(t *testing.T) {
 		t.Errorf("expected ~24 hours from now, got %v", diff)
 	}
 }
+
+func TestScheduler_RunScheduleDeliversReport(t *testing.T) {
+	t.Parallel()
+
+	store := newMockSchedulerStore()
+	store.devices = []*storage.Device{newTestDevice("SN001", "LaserJet", "10.0.0.1", "agent-1")}
+	store.reports[1] = &storage.ReportDefinition{
+		ID:              1,
+		Name:            "Monthly SLA",
+		Type:            storage.ReportTypeDeviceInventory,
+		Format:          storage.ReportFormatCSV,
+		EmailRecipients: []string{" ops@example.com ", ""},
+	}
+	store.reports[2] = &storage.ReportDefinition{
+		ID:              2,
+		Name:            "Broken mail",
+		Type:            storage.ReportTypeDeviceInventory,
+		Format:          storage.ReportFormatCSV,
+		EmailRecipients: []string{"ops@example.com"},
+	}
+
+	var mu sync.Mutex
+	var sent []string
+	s := NewScheduler(store, testLogger())
+	s.SetDelivery(func(ctx context.Context, report *storage.ReportDefinition, run *storage.ReportRun, data []byte) error {
+		if report.ID == 2 {
+			return errors.New("SMTP not configured")
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		sent = append(sent, report.EmailRecipients...)
+		if len(data) == 0 {
+			t.Error("delivery got an empty report")
+		}
+		return nil
+	})
+
+	ctx := context.Background()
+	run, err := s.RunSchedule(ctx, &storage.ReportSchedule{ID: 1, ReportID: 1, Frequency: storage.ScheduleFrequencyDaily})
+	if err != nil {
+		t.Fatalf("RunSchedule failed: %v", err)
+	}
+	if len(sent) != 1 || sent[0] != "ops@example.com" {
+		t.Errorf("unexpected recipients: %v", sent)
+	}
+	if len(run.DeliveredTo) != 1 || run.DeliveredAt == nil || run.DeliveryError != "" {
+		t.Errorf("delivery not recorded: %+v", run)
+	}
+
+	// A failed delivery is recorded but the run itself still succeeds
+	run, err = s.RunSchedule(ctx, &storage.ReportSchedule{ID: 2, ReportID: 2, Frequency: storage.ScheduleFrequencyDaily})
+	if err != nil {
+		t.Fatalf("RunSchedule failed: %v", err)
+	}
+	if run.Status != storage.ReportStatusCompleted || run.DeliveryError != "SMTP not configured" || run.DeliveredAt != nil {
+		t.Errorf("unexpected run after failed delivery: %+v", run)
+	}
+	if calls := store.getScheduleCalls(); len(calls) != 2 || calls[1].failed {
+		t.Errorf("schedule should advance without failing: %+v", calls)
+	}
+}
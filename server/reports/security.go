@@ -0,0 +1,385 @@
+package reports
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"printmaster/server/storage"
+)
+
+// ---------- Security Reports ----------
+
+// Security finding severities, ordered from least to most severe.
+const (
+	SecuritySeverityLow    = "low"
+	SecuritySeverityMedium = "medium"
+	SecuritySeverityHigh   = "high"
+)
+
+// securitySeverityPoints is the score penalty applied per finding.
+var securitySeverityPoints = map[string]int{
+	SecuritySeverityLow:    5,
+	SecuritySeverityMedium: 15,
+	SecuritySeverityHigh:   25,
+}
+
+var securitySeverityRank = map[string]int{
+	SecuritySeverityLow:    1,
+	SecuritySeverityMedium: 2,
+	SecuritySeverityHigh:   3,
+}
+
+// Security check identifiers.
+const (
+	SecurityCheckDefaultCommunity = "default_snmp_community"
+	SecurityCheckSNMPv1           = "snmpv1_enabled"
+	SecurityCheckTelnet           = "telnet_open"
+	SecurityCheckFTP              = "ftp_open"
+	SecurityCheckOutdatedFirmware = "outdated_firmware"
+	SecurityCheckSelfSignedCert   = "self_signed_cert"
+	SecurityCheckExpiredCert      = "expired_cert"
+	SecurityCheckPlainHTTP        = "web_ui_without_tls"
+)
+
+// SecurityFinding is a single security issue detected on a device.
+type SecurityFinding struct {
+	Check    string `json:"check"`
+	Severity string `json:"severity"`
+	Detail   string `json:"detail"`
+}
+
+// EvaluateDeviceSecurity runs all posture checks against a device. newestFirmware
+// is the newest firmware seen for the same model; pass "" to skip the check.
+func EvaluateDeviceSecurity(d *storage.Device, newestFirmware string, now time.Time) []SecurityFinding {
+	var findings []SecurityFinding
+	add := func(check, severity, detail string) {
+		findings = append(findings, SecurityFinding{Check: check, Severity: severity, Detail: detail})
+	}
+
+	if v, ok := deviceRawValue(d, "snmp_default_community").(bool); ok && v {
+		add(SecurityCheckDefaultCommunity, SecuritySeverityHigh, "Device accepts a default SNMP community string")
+	}
+	if deviceAcceptsSNMPv1(d) {
+		add(SecurityCheckSNMPv1, SecuritySeverityMedium, "SNMPv1 enabled (cleartext, no bulk security)")
+	}
+
+	ports := deviceOpenPorts(d)
+	if ports[23] {
+		add(SecurityCheckTelnet, SecuritySeverityHigh, "Telnet (TCP 23) is open")
+	}
+	if ports[21] {
+		add(SecurityCheckFTP, SecuritySeverityMedium, "FTP (TCP 21) is open")
+	}
+
+	if newestFirmware != "" && d.Firmware != "" && compareFirmware(d.Firmware, newestFirmware) < 0 {
+		add(SecurityCheckOutdatedFirmware, SecuritySeverityMedium,
+			fmt.Sprintf("Firmware %s is older than %s seen on other devices of this model", d.Firmware, newestFirmware))
+	}
+
+	fp, _ := deviceRawValue(d, "web_fingerprint").(map[string]interface{})
+	hasCert := false
+	if fp != nil {
+		if sha, _ := fp["cert_sha256"].(string); sha != "" {
+			hasCert = true
+		}
+		if v, ok := fp["cert_self_signed"].(bool); ok && v {
+			add(SecurityCheckSelfSignedCert, SecuritySeverityLow, "Web UI uses a self-signed certificate")
+		}
+		if s, ok := fp["cert_not_after"].(string); ok && s != "" {
+			if notAfter, err := time.Parse(time.RFC3339, s); err == nil && notAfter.Before(now) {
+				add(SecurityCheckExpiredCert, SecuritySeverityMedium,
+					fmt.Sprintf("Web UI certificate expired %s", notAfter.Format("2006-01-02")))
+			}
+		}
+	}
+	if !hasCert && strings.HasPrefix(strings.ToLower(d.WebUIURL), "http://") {
+		add(SecurityCheckPlainHTTP, SecuritySeverityLow, "Web UI is only served over plain HTTP")
+	}
+
+	return findings
+}
+
+// SecurityScore converts findings into a 0-100 score (100 = no findings) and
+// the worst severity present ("none" when clean).
+func SecurityScore(findings []SecurityFinding) (int, string) {
+	score := 100
+	risk := "none"
+	for _, f := range findings {
+		score -= securitySeverityPoints[f.Severity]
+		if securitySeverityRank[f.Severity] > securitySeverityRank[risk] {
+			risk = f.Severity
+		}
+	}
+	if score < 0 {
+		score = 0
+	}
+	return score, risk
+}
+
+func (g *Generator) generateSecurityPosture(ctx context.Context, params GenerateParams) (*GenerateResult, error) {
+	devices, err := g.store.ListAllDevices(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("list devices: %w", err)
+	}
+	agents, err := g.store.ListAgents(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("list agents: %w", err)
+	}
+
+	if devices, err = g.filterDevices(ctx, devices, params.Report); err != nil {
+		return nil, err
+	}
+
+	// Devices carry no tenant; resolve it through the discovering agent
+	agentTenant := make(map[string]string, len(agents))
+	for _, a := range agents {
+		agentTenant[a.AgentID] = a.TenantID
+	}
+	tenantFilter := make(map[string]bool)
+	for _, id := range params.Report.TenantIDs {
+		tenantFilter[id] = true
+	}
+	if len(tenantFilter) > 0 {
+		scoped := devices[:0:0]
+		for _, d := range devices {
+			if tenantFilter[agentTenant[d.AgentID]] {
+				scoped = append(scoped, d)
+			}
+		}
+		devices = scoped
+	}
+
+	// Newest firmware per tenant+model is the baseline for "outdated"
+	newest := make(map[string]string)
+	firmwareKey := func(d *storage.Device) string {
+		return agentTenant[d.AgentID] + "|" + strings.ToLower(d.Manufacturer) + "|" + strings.ToLower(d.Model)
+	}
+	for _, d := range devices {
+		if d.Firmware == "" || d.Model == "" {
+			continue
+		}
+		key := firmwareKey(d)
+		if cur, ok := newest[key]; !ok || compareFirmware(d.Firmware, cur) > 0 {
+			newest[key] = d.Firmware
+		}
+	}
+
+	now := time.Now().UTC()
+	checkCounts := make(map[string]int)
+	severityCounts := make(map[string]int)
+	type tenantTotals struct{ devices, score int }
+	tenants := make(map[string]*tenantTotals)
+	totalScore := 0
+	affected := 0
+
+	rows := make([]map[string]any, 0, len(devices))
+	for _, d := range devices {
+		baseline := ""
+		if d.Model != "" {
+			baseline = newest[firmwareKey(d)]
+		}
+		findings := EvaluateDeviceSecurity(d, baseline, now)
+		score, risk := SecurityScore(findings)
+
+		details := make([]string, 0, len(findings))
+		for _, f := range findings {
+			checkCounts[f.Check]++
+			severityCounts[f.Severity]++
+			details = append(details, f.Severity+": "+f.Detail)
+		}
+		if len(findings) > 0 {
+			affected++
+		}
+		totalScore += score
+
+		tenantID := agentTenant[d.AgentID]
+		tt := tenants[tenantID]
+		if tt == nil {
+			tt = &tenantTotals{}
+			tenants[tenantID] = tt
+		}
+		tt.devices++
+		tt.score += score
+
+		rows = append(rows, map[string]any{
+			"tenant_id":     tenantID,
+			"serial":        d.Serial,
+			"manufacturer":  d.Manufacturer,
+			"model":         d.Model,
+			"ip":            d.IP,
+			"firmware":      d.Firmware,
+			"agent_id":      d.AgentID,
+			"score":         score,
+			"risk":          risk,
+			"findings":      details,
+			"finding_count": len(findings),
+			"last_seen":     d.LastSeen,
+		})
+	}
+
+	// Worst devices first
+	sort.SliceStable(rows, func(i, j int) bool {
+		si, _ := rows[i]["score"].(int)
+		sj, _ := rows[j]["score"].(int)
+		return si < sj
+	})
+
+	fleetScore := 100
+	if len(devices) > 0 {
+		fleetScore = totalScore / len(devices)
+	}
+	tenantScores := make(map[string]any, len(tenants))
+	for id, tt := range tenants {
+		tenantScores[id] = tt.score / tt.devices
+	}
+
+	columns := []string{
+		"tenant_id", "serial", "manufacturer", "model", "ip", "firmware",
+		"agent_id", "score", "risk", "findings", "finding_count", "last_seen",
+	}
+
+	return &GenerateResult{
+		Rows:     rows,
+		Columns:  columns,
+		RowCount: len(rows),
+		Summary: map[string]any{
+			"total_devices":         len(devices),
+			"devices_with_findings": affected,
+			"security_score":        fleetScore,
+			"findings_by_check":     checkCounts,
+			"findings_by_severity":  severityCounts,
+			"tenant_scores":         tenantScores,
+		},
+		Metadata: map[string]string{
+			"report_type": string(params.Report.Type),
+			"generated":   now.Format(time.RFC3339),
+		},
+	}, nil
+}
+
+// deviceRawValue looks up key in a device's raw data. Uploaded devices keep the
+// agent's raw_data nested under "raw_data", so both levels are checked.
+func deviceRawValue(d *storage.Device, key string) interface{} {
+	if d == nil || d.RawData == nil {
+		return nil
+	}
+	if v, ok := d.RawData[key]; ok && v != nil {
+		return v
+	}
+	if nested, ok := d.RawData["raw_data"].(map[string]interface{}); ok {
+		return nested[key]
+	}
+	return nil
+}
+
+// deviceServiceScan returns the agent's optional service scan result, if any.
+func deviceServiceScan(d *storage.Device) map[string]interface{} {
+	scan, _ := deviceRawValue(d, "service_scan").(map[string]interface{})
+	return scan
+}
+
+// deviceOpenPorts returns the set of open TCP ports recorded by the agent,
+// combining discovery probes with the service scan.
+func deviceOpenPorts(d *storage.Device) map[int]bool {
+	ports := make(map[int]bool)
+	collect := func(v interface{}) {
+		switch v := v.(type) {
+		case []interface{}:
+			for _, p := range v {
+				if n := toInt(p); n > 0 {
+					ports[n] = true
+				}
+			}
+		case []int:
+			for _, n := range v {
+				ports[n] = true
+			}
+		}
+	}
+	collect(deviceRawValue(d, "open_ports"))
+	if scan := deviceServiceScan(d); scan != nil {
+		collect(scan["open_ports"])
+	}
+	return ports
+}
+
+// deviceAcceptsSNMPv1 reports whether the device answered SNMPv1, either during
+// discovery or in the service scan.
+func deviceAcceptsSNMPv1(d *storage.Device) bool {
+	if v, ok := deviceRawValue(d, "snmp_version").(string); ok && v == "1" {
+		return true
+	}
+	if scan := deviceServiceScan(d); scan != nil {
+		if versions, ok := scan["snmp_versions"].([]interface{}); ok {
+			for _, v := range versions {
+				if s, _ := v.(string); s == "1" {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+// compareFirmware compares two firmware version strings, treating digit runs
+// numerically so "2.10" sorts after "2.9". It returns -1, 0 or 1.
+func compareFirmware(a, b string) int {
+	ta, tb := firmwareTokens(a), firmwareTokens(b)
+	for i := 0; i < len(ta) && i < len(tb); i++ {
+		na, errA := strconv.Atoi(ta[i])
+		nb, errB := strconv.Atoi(tb[i])
+		switch {
+		case errA == nil && errB == nil:
+			if na != nb {
+				if na < nb {
+					return -1
+				}
+				return 1
+			}
+		default:
+			if c := strings.Compare(ta[i], tb[i]); c != 0 {
+				return c
+			}
+		}
+	}
+	switch {
+	case len(ta) < len(tb):
+		return -1
+	case len(ta) > len(tb):
+		return 1
+	}
+	return 0
+}
+
+// firmwareTokens splits a version string into alternating digit and letter
+// runs, dropping separators.
+func firmwareTokens(s string) []string {
+	var tokens []string
+	var cur strings.Builder
+	curDigit := false
+	flush := func() {
+		if cur.Len() > 0 {
+			tokens = append(tokens, cur.String())
+			cur.Reset()
+		}
+	}
+	for _, r := range strings.ToLower(s) {
+		isDigit := r >= '0' && r <= '9'
+		isLetter := r >= 'a' && r <= 'z'
+		if !isDigit && !isLetter {
+			flush()
+			continue
+		}
+		if cur.Len() > 0 && isDigit != curDigit {
+			flush()
+		}
+		curDigit = isDigit
+		cur.WriteRune(r)
+	}
+	flush()
+	return tokens
+}
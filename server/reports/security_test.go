@@ -0,0 +1,145 @@
+package reports
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"printmaster/server/storage"
+)
+
+func TestGenerator_SecurityPosture(t *testing.T) {
+	t.Parallel()
+
+	store := newMockGeneratorStore()
+
+	insecure := newTestDevice("SN001", "MX-3070", "10.0.0.10", "agent-1")
+	insecure.Manufacturer = "Sharp"
+	insecure.Firmware = "2.9.1"
+	insecure.WebUIURL = "https://10.0.0.10"
+	insecure.RawData = map[string]interface{}{
+		// Uploaded devices nest the agent's raw data
+		"raw_data": map[string]interface{}{
+			"snmp_version":           "1",
+			"snmp_default_community": true,
+			"open_ports":             []interface{}{float64(21), float64(23), float64(80)},
+			"web_fingerprint": map[string]interface{}{
+				"cert_sha256":      "abc",
+				"cert_self_signed": true,
+				"cert_not_after":   "2020-01-01T00:00:00Z",
+			},
+		},
+	}
+
+	current := newTestDevice("SN002", "MX-3070", "10.0.0.11", "agent-1")
+	current.Manufacturer = "Sharp"
+	current.Firmware = "2.10.0"
+	current.WebUIURL = "https://10.0.0.11"
+	current.RawData = map[string]interface{}{
+		"snmp_version":    "3",
+		"web_fingerprint": map[string]interface{}{"cert_sha256": "def"},
+	}
+
+	otherTenant := newTestDevice("SN003", "MX-3070", "10.1.0.10", "agent-2")
+	otherTenant.Manufacturer = "Sharp"
+	otherTenant.Firmware = "3.0.0"
+	otherTenant.WebUIURL = "http://10.1.0.10"
+
+	store.devices = []*storage.Device{current, insecure, otherTenant}
+	store.agents = []*storage.Agent{
+		{AgentID: "agent-1", TenantID: "tenant-a"},
+		{AgentID: "agent-2", TenantID: "tenant-b"},
+	}
+
+	gen := NewGenerator(store)
+	result, err := gen.Generate(context.Background(), GenerateParams{
+		Report: &storage.ReportDefinition{
+			Type:      "security.posture",
+			TenantIDs: []string{"tenant-a"},
+		},
+	})
+	if err != nil {
+		t.Fatalf("Generate failed: %v", err)
+	}
+	if result.RowCount != 2 {
+		t.Fatalf("expected 2 rows for tenant-a, got %d", result.RowCount)
+	}
+
+	worst := result.Rows[0]
+	if worst["serial"] != "SN001" {
+		t.Fatalf("expected least secure device first, got %v", worst["serial"])
+	}
+	// high(25)*2 + medium(15)*4 + low(5) = 115 -> clamped to 0
+	if worst["score"] != 0 || worst["risk"] != SecuritySeverityHigh {
+		t.Errorf("unexpected score/risk: %v/%v", worst["score"], worst["risk"])
+	}
+
+	checks := result.Summary["findings_by_check"].(map[string]int)
+	for _, check := range []string{
+		SecurityCheckDefaultCommunity, SecurityCheckSNMPv1, SecurityCheckTelnet, SecurityCheckFTP,
+		SecurityCheckOutdatedFirmware, SecurityCheckSelfSignedCert, SecurityCheckExpiredCert,
+	} {
+		if checks[check] != 1 {
+			t.Errorf("findings_by_check[%s] = %d, want 1", check, checks[check])
+		}
+	}
+	// Firmware baseline is per tenant, so tenant-b's newer firmware does not
+	// make SN002 outdated.
+	if clean := result.Rows[1]; clean["serial"] != "SN002" || clean["score"] != 100 || clean["risk"] != "none" {
+		t.Errorf("expected SN002 to be clean, got %+v", clean)
+	}
+}
+
+func TestEvaluateDeviceSecurity_PlainHTTP(t *testing.T) {
+	t.Parallel()
+
+	d := newTestDevice("SN010", "Model", "10.0.0.1", "agent-1")
+	d.WebUIURL = "http://10.0.0.1"
+	findings := EvaluateDeviceSecurity(d, "", time.Now())
+	if len(findings) != 1 || findings[0].Check != SecurityCheckPlainHTTP {
+		t.Fatalf("unexpected findings: %+v", findings)
+	}
+}
+
+func TestEvaluateDeviceSecurity_ServiceScan(t *testing.T) {
+	t.Parallel()
+
+	d := newTestDevice("SN011", "Model", "10.0.0.2", "agent-1")
+	d.RawData = map[string]interface{}{
+		"raw_data": map[string]interface{}{
+			"service_scan": map[string]interface{}{
+				"open_ports":    []interface{}{float64(23), float64(9100)},
+				"snmp_versions": []interface{}{"1", "2c"},
+			},
+		},
+	}
+	findings := EvaluateDeviceSecurity(d, "", time.Now())
+	got := map[string]bool{}
+	for _, f := range findings {
+		got[f.Check] = true
+	}
+	if !got[SecurityCheckTelnet] || !got[SecurityCheckSNMPv1] || len(findings) != 2 {
+		t.Fatalf("unexpected findings: %+v", findings)
+	}
+}
+
+func TestCompareFirmware(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"2.9", "2.10", -1},
+		{"2.10", "2.9", 1},
+		{"1.0.0", "1.0.0", 0},
+		{"V1.05", "v1.5", 0},
+		{"3.0", "3.0.1", -1},
+		{"20230101", "20221231", 1},
+	}
+	for _, tt := range tests {
+		if got := compareFirmware(tt.a, tt.b); got != tt.want {
+			t.Errorf("compareFirmware(%q, %q) = %d, want %d", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
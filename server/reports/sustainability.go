@@ -0,0 +1,396 @@
+package reports
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ---------- Sustainability Reports ----------
+
+// Trend intervals for the sustainability report.
+const (
+	IntervalDay   = "day"
+	IntervalWeek  = "week"
+	IntervalMonth = "month"
+)
+
+// sustainabilityTotalPeriod labels the per-tenant row covering the whole
+// report period.
+const sustainabilityTotalPeriod = "total"
+
+// SustainabilityOptions configures the sustainability report. It is read
+// from the report's OptionsJSON. The defaults are rough office averages for
+// A4 80 g/m² paper and laser printers; tenders usually state their own.
+type SustainabilityOptions struct {
+	SheetWeightGrams      float64 `json:"sheet_weight_grams,omitempty"`
+	PaperCO2GramsPerSheet float64 `json:"paper_co2_grams_per_sheet,omitempty"`
+	EnergyWhPerPage       float64 `json:"energy_wh_per_page,omitempty"`
+	GridCO2GramsPerKWh    float64 `json:"grid_co2_grams_per_kwh,omitempty"`
+	// Interval buckets the trend rows; empty picks day, week or month from
+	// the length of the report period.
+	Interval string `json:"interval,omitempty"`
+}
+
+// ParseSustainabilityOptions decodes sustainability options and fills in
+// defaults.
+func ParseSustainabilityOptions(raw string) (*SustainabilityOptions, error) {
+	opts := &SustainabilityOptions{}
+	if strings.TrimSpace(raw) != "" {
+		if err := json.Unmarshal([]byte(raw), opts); err != nil {
+			return nil, fmt.Errorf("invalid sustainability options: %w", err)
+		}
+	}
+	switch opts.Interval {
+	case "", IntervalDay, IntervalWeek, IntervalMonth:
+	default:
+		return nil, fmt.Errorf("invalid sustainability options: interval must be day, week or month")
+	}
+	if opts.SheetWeightGrams <= 0 {
+		opts.SheetWeightGrams = 5
+	}
+	if opts.PaperCO2GramsPerSheet <= 0 {
+		opts.PaperCO2GramsPerSheet = 5
+	}
+	if opts.EnergyWhPerPage <= 0 {
+		opts.EnergyWhPerPage = 1.5
+	}
+	if opts.GridCO2GramsPerKWh <= 0 {
+		opts.GridCO2GramsPerKWh = 400
+	}
+	return opts, nil
+}
+
+// intervalFor returns the configured interval, or one that gives a readable
+// number of trend points for the period.
+func (o *SustainabilityOptions) intervalFor(start, end time.Time) string {
+	if o.Interval != "" {
+		return o.Interval
+	}
+	switch days := end.Sub(start).Hours() / 24; {
+	case days > 92:
+		return IntervalMonth
+	case days > 31:
+		return IntervalWeek
+	default:
+		return IntervalDay
+	}
+}
+
+// sustainabilityBuckets splits [start, end) into interval periods aligned to
+// calendar days (UTC), ISO weeks or months. The first and last periods are
+// clipped to the report period.
+func sustainabilityBuckets(start, end time.Time, interval string) []time.Time {
+	start, end = start.UTC(), end.UTC()
+	cur := time.Date(start.Year(), start.Month(), start.Day(), 0, 0, 0, 0, time.UTC)
+	switch interval {
+	case IntervalWeek:
+		cur = cur.AddDate(0, 0, -((int(cur.Weekday()) + 6) % 7))
+	case IntervalMonth:
+		cur = time.Date(cur.Year(), cur.Month(), 1, 0, 0, 0, 0, time.UTC)
+	}
+	bounds := []time.Time{start}
+	for {
+		switch interval {
+		case IntervalWeek:
+			cur = cur.AddDate(0, 0, 7)
+		case IntervalMonth:
+			cur = cur.AddDate(0, 1, 0)
+		default:
+			cur = cur.AddDate(0, 0, 1)
+		}
+		if !cur.Before(end) {
+			break
+		}
+		bounds = append(bounds, cur)
+	}
+	return append(bounds, end)
+}
+
+// periodLabel names the period starting at t.
+func periodLabel(t time.Time, interval string) string {
+	switch interval {
+	case IntervalMonth:
+		return t.Format("2006-01")
+	case IntervalWeek:
+		year, week := t.ISOWeek()
+		return fmt.Sprintf("%d-W%02d", year, week)
+	default:
+		return t.Format("2006-01-02")
+	}
+}
+
+// sustainabilityUsage accumulates volumes for one tenant and period.
+type sustainabilityUsage struct {
+	devices      map[string]bool
+	pages        int64
+	duplexSheets int64
+	// Pages of devices that report a duplex counter; the adoption rate is
+	// only measured over these
+	duplexReportingPages int64
+}
+
+func (u *sustainabilityUsage) add(serial string, pages, duplexSheets int64, reportsDuplex bool) {
+	if u.devices == nil {
+		u.devices = make(map[string]bool)
+	}
+	u.devices[serial] = true
+	u.pages += pages
+	if reportsDuplex {
+		u.duplexSheets += duplexSheets
+		u.duplexReportingPages += pages
+	}
+}
+
+// sheets is the paper used: a duplex sheet carries two of the pages.
+func (u *sustainabilityUsage) sheets() int64 {
+	return u.pages - u.duplexSheets
+}
+
+func (u *sustainabilityUsage) row(opts *SustainabilityOptions) map[string]any {
+	sheets := u.sheets()
+	energyKWh := float64(u.pages) * opts.EnergyWhPerPage / 1000
+	co2Grams := float64(sheets)*opts.PaperCO2GramsPerSheet + energyKWh*opts.GridCO2GramsPerKWh
+	row := map[string]any{
+		"devices":                len(u.devices),
+		"pages":                  u.pages,
+		"sheets":                 sheets,
+		"sheets_saved_by_duplex": u.duplexSheets,
+		"paper_kg":               round2(float64(sheets) * opts.SheetWeightGrams / 1000),
+		"energy_kwh":             round2(energyKWh),
+		"co2_kg":                 round2(co2Grams / 1000),
+	}
+	if u.duplexReportingPages > 0 {
+		row["duplex_rate_pct"] = percentOf64(2*u.duplexSheets, u.duplexReportingPages)
+	}
+	return row
+}
+
+// counterReading is a device's page and duplex counters at one time.
+type counterReading struct {
+	at           time.Time
+	pages        int
+	duplexSheets int
+}
+
+func (g *Generator) generateSustainability(ctx context.Context, params GenerateParams) (*GenerateResult, error) {
+	opts, err := ParseSustainabilityOptions(params.Report.OptionsJSON)
+	if err != nil {
+		return nil, err
+	}
+
+	devices, err := g.store.ListAllDevices(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("list devices: %w", err)
+	}
+	agents, err := g.store.ListAgents(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("list agents: %w", err)
+	}
+	tenants, err := g.store.ListTenants(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("list tenants: %w", err)
+	}
+	if devices, err = g.filterDevices(ctx, devices, params.Report); err != nil {
+		return nil, err
+	}
+
+	agentTenant := make(map[string]string, len(agents))
+	for _, a := range agents {
+		agentTenant[a.AgentID] = a.TenantID
+	}
+	tenantName := make(map[string]string, len(tenants))
+	for _, t := range tenants {
+		tenantName[t.ID] = t.Name
+	}
+	tenantFilter := make(map[string]bool)
+	for _, id := range params.Report.TenantIDs {
+		tenantFilter[id] = true
+	}
+
+	end := params.EndTime
+	if end.IsZero() {
+		end = time.Now().UTC()
+	}
+	start := params.StartTime
+	if start.IsZero() {
+		start = end.AddDate(0, 0, -daysPerMonth)
+	}
+	interval := opts.intervalFor(start, end)
+	bounds := sustainabilityBuckets(start, end, interval)
+
+	// usage[tenant][i] covers bounds[i]..bounds[i+1]
+	usage := make(map[string][]*sustainabilityUsage)
+	totals := make(map[string]*sustainabilityUsage)
+	fleet := &sustainabilityUsage{}
+	devicesWithDuplex := 0
+
+	for _, d := range devices {
+		if d == nil || d.Serial == "" {
+			continue
+		}
+		tenantID := agentTenant[d.AgentID]
+		if len(tenantFilter) > 0 && !tenantFilter[tenantID] {
+			continue
+		}
+		readings, err := g.counterReadings(ctx, d.Serial, start)
+		if err != nil {
+			return nil, fmt.Errorf("metrics for %s: %w", d.Serial, err)
+		}
+		if len(readings) == 0 {
+			continue
+		}
+		reportsDuplex := false
+		for _, r := range readings {
+			if r.duplexSheets > 0 {
+				reportsDuplex = true
+				break
+			}
+		}
+		if reportsDuplex {
+			devicesWithDuplex++
+		}
+
+		if usage[tenantID] == nil {
+			usage[tenantID] = make([]*sustainabilityUsage, len(bounds)-1)
+			for i := range usage[tenantID] {
+				usage[tenantID][i] = &sustainabilityUsage{}
+			}
+			totals[tenantID] = &sustainabilityUsage{}
+		}
+		prev := counterAt(readings, bounds[0])
+		for i := 1; i < len(bounds); i++ {
+			cur := counterAt(readings, bounds[i])
+			pages := int64(cur.pages - prev.pages)
+			duplex := int64(cur.duplexSheets - prev.duplexSheets)
+			prev = cur
+			if pages <= 0 {
+				// No printing, or a counter reset that can't be measured
+				continue
+			}
+			if duplex < 0 || 2*duplex > pages {
+				duplex = 0
+			}
+			usage[tenantID][i-1].add(d.Serial, pages, duplex, reportsDuplex)
+			totals[tenantID].add(d.Serial, pages, duplex, reportsDuplex)
+			fleet.add(d.Serial, pages, duplex, reportsDuplex)
+		}
+	}
+
+	tenantIDs := make([]string, 0, len(usage))
+	for id := range usage {
+		tenantIDs = append(tenantIDs, id)
+	}
+	sort.Strings(tenantIDs)
+
+	var rows []map[string]any
+	for _, id := range tenantIDs {
+		for i, u := range usage[id] {
+			row := u.row(opts)
+			row["tenant_id"] = id
+			row["tenant_name"] = tenantName[id]
+			row["period"] = periodLabel(bounds[i], interval)
+			row["period_start"] = bounds[i]
+			rows = append(rows, row)
+		}
+		row := totals[id].row(opts)
+		row["tenant_id"] = id
+		row["tenant_name"] = tenantName[id]
+		row["period"] = sustainabilityTotalPeriod
+		row["period_start"] = start
+		rows = append(rows, row)
+	}
+	if params.Report.Limit > 0 && len(rows) > params.Report.Limit {
+		rows = rows[:params.Report.Limit]
+	}
+
+	columns := []string{
+		"tenant_id", "tenant_name", "period", "devices", "pages", "sheets",
+		"sheets_saved_by_duplex", "duplex_rate_pct", "paper_kg", "energy_kwh", "co2_kg",
+	}
+
+	fleetRow := fleet.row(opts)
+	summary := map[string]any{
+		"period_start":                start,
+		"period_end":                  end,
+		"interval":                    interval,
+		"tenants":                     len(tenantIDs),
+		"devices":                     len(fleet.devices),
+		"devices_with_duplex_counter": devicesWithDuplex,
+		"total_pages":                 fleetRow["pages"],
+		"total_sheets":                fleetRow["sheets"],
+		"sheets_saved_by_duplex":      fleetRow["sheets_saved_by_duplex"],
+		"paper_kg":                    fleetRow["paper_kg"],
+		"energy_kwh":                  fleetRow["energy_kwh"],
+		"co2_kg":                      fleetRow["co2_kg"],
+		"sheet_weight_grams":          opts.SheetWeightGrams,
+		"paper_co2_grams_per_sheet":   opts.PaperCO2GramsPerSheet,
+		"energy_wh_per_page":          opts.EnergyWhPerPage,
+		"grid_co2_grams_per_kwh":      opts.GridCO2GramsPerKWh,
+	}
+	if rate, ok := fleetRow["duplex_rate_pct"]; ok {
+		summary["duplex_rate_pct"] = rate
+	}
+
+	return &GenerateResult{
+		Rows:     rows,
+		Columns:  columns,
+		RowCount: len(rows),
+		Summary:  summary,
+		Metadata: map[string]string{
+			"report_type": string(params.Report.Type),
+			"generated":   time.Now().UTC().Format(time.RFC3339),
+		},
+	}, nil
+}
+
+// counterReadings returns the device's readings from the last one before
+// start onwards, oldest first.
+func (g *Generator) counterReadings(ctx context.Context, serial string, start time.Time) ([]counterReading, error) {
+	var readings []counterReading
+	baseline, err := g.store.GetMetricsAtOrBefore(ctx, serial, start)
+	if err != nil {
+		return nil, err
+	}
+	if baseline != nil {
+		readings = append(readings, counterReading{baseline.Timestamp, baseline.PageCount, baseline.DuplexSheets})
+	}
+	hist, err := g.store.GetMetricsHistory(ctx, serial, start)
+	if err != nil {
+		return nil, err
+	}
+	for _, m := range hist {
+		if m == nil || (baseline != nil && !m.Timestamp.After(baseline.Timestamp)) {
+			continue
+		}
+		readings = append(readings, counterReading{m.Timestamp, m.PageCount, m.DuplexSheets})
+	}
+	return readings, nil
+}
+
+// counterAt returns the last reading at or before t. Before the first
+// reading the device wasn't tracked yet, so the first reading stands in and
+// those periods count no pages.
+func counterAt(readings []counterReading, t time.Time) counterReading {
+	i := sort.Search(len(readings), func(i int) bool { return readings[i].at.After(t) })
+	if i == 0 {
+		return readings[0]
+	}
+	return readings[i-1]
+}
+
+// percentOf64 returns part as a percentage of whole, rounded to one decimal.
+func percentOf64(part, whole int64) float64 {
+	if whole <= 0 {
+		return 0
+	}
+	return math.Round(float64(part)*1000/float64(whole)) / 10
+}
+
+func round2(v float64) float64 {
+	return math.Round(v*100) / 100
+}
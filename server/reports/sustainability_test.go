@@ -0,0 +1,88 @@
+package reports
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"printmaster/server/storage"
+)
+
+func TestGenerator_Sustainability(t *testing.T) {
+	t.Parallel()
+
+	store := newMockGeneratorStore()
+	store.agents = []*storage.Agent{
+		{AgentID: "agent-1", TenantID: "tenant-a"},
+		{AgentID: "agent-2", TenantID: "tenant-b"},
+	}
+	store.tenants = []*storage.Tenant{{ID: "tenant-a", Name: "Acme"}}
+	store.devices = []*storage.Device{
+		newTestDevice("DUPLEX", "LaserJet M404", "10.0.0.1", "agent-1"),
+		newTestDevice("SIMPLEX", "LaserJet M15", "10.0.0.2", "agent-1"),
+		newTestDevice("RESET", "LaserJet M404", "10.1.0.1", "agent-2"),
+	}
+	day := func(m time.Month, d int) time.Time { return time.Date(2026, m, d, 12, 0, 0, 0, time.UTC) }
+	reading := func(serial string, at time.Time, pages, duplex int) *storage.MetricsSnapshot {
+		return &storage.MetricsSnapshot{Serial: serial, Timestamp: at, PageCount: pages, DuplexSheets: duplex}
+	}
+	store.metricsHist["DUPLEX"] = []*storage.MetricsSnapshot{
+		reading("DUPLEX", day(time.July, 31), 1000, 100),
+		reading("DUPLEX", day(time.August, 31), 2000, 400), // August: 1000 pages, 300 duplex sheets
+		reading("DUPLEX", day(time.September, 29), 2500, 500),
+	}
+	store.metricsHist["SIMPLEX"] = []*storage.MetricsSnapshot{
+		reading("SIMPLEX", day(time.July, 31), 0, 0),
+		reading("SIMPLEX", day(time.September, 15), 1000, 0),
+	}
+	store.metricsHist["RESET"] = []*storage.MetricsSnapshot{
+		reading("RESET", day(time.August, 2), 5000, 0),
+		reading("RESET", day(time.August, 20), 100, 0),
+	}
+
+	if _, err := ParseSustainabilityOptions(`{"interval":"quarter"}`); err == nil {
+		t.Fatal("expected an invalid interval to be rejected")
+	}
+	result, err := NewGenerator(store).Generate(context.Background(), GenerateParams{
+		Report: &storage.ReportDefinition{
+			Type:        storage.ReportTypeSustainability,
+			OptionsJSON: `{"interval":"month"}`,
+		},
+		StartTime: time.Date(2026, time.August, 1, 0, 0, 0, 0, time.UTC),
+		EndTime:   time.Date(2026, time.October, 1, 0, 0, 0, 0, time.UTC),
+	})
+	if err != nil {
+		t.Fatalf("Generate failed: %v", err)
+	}
+
+	rows := make(map[string]map[string]any)
+	for _, row := range result.Rows {
+		rows[row["tenant_id"].(string)+"/"+row["period"].(string)] = row
+	}
+	if len(result.Rows) != 6 {
+		t.Fatalf("expected 2 periods and a total for each tenant, got %d rows: %+v", len(result.Rows), result.Rows)
+	}
+
+	aug := rows["tenant-a/2026-08"]
+	if aug["pages"] != int64(1000) || aug["sheets"] != int64(700) || aug["duplex_rate_pct"] != 60.0 || aug["devices"] != 1 {
+		t.Errorf("unexpected August row: %+v", aug)
+	}
+	sep := rows["tenant-a/2026-09"]
+	if sep["pages"] != int64(1500) || sep["sheets"] != int64(1400) || sep["duplex_rate_pct"] != 40.0 || sep["devices"] != 2 {
+		t.Errorf("unexpected September row: %+v", sep)
+	}
+	total := rows["tenant-a/total"]
+	if total["tenant_name"] != "Acme" || total["pages"] != int64(2500) || total["sheets"] != int64(2100) ||
+		total["duplex_rate_pct"] != 53.3 || total["paper_kg"] != 10.5 || total["energy_kwh"] != 3.75 || total["co2_kg"] != 12.0 {
+		t.Errorf("unexpected tenant total: %+v", total)
+	}
+	// A counter reset can't be measured, and a device without a duplex
+	// counter has no adoption rate
+	if reset := rows["tenant-b/total"]; reset["pages"] != int64(0) || reset["duplex_rate_pct"] != nil {
+		t.Errorf("unexpected reset row: %+v", reset)
+	}
+
+	if result.Summary["interval"] != IntervalMonth || result.Summary["devices_with_duplex_counter"] != 1 || result.Summary["co2_kg"] != 12.0 {
+		t.Errorf("unexpected summary: %+v", result.Summary)
+	}
+}
@@ -0,0 +1,151 @@
+package reports
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/base64"
+	"encoding/xml"
+	"fmt"
+	"sort"
+	"strconv"
+
+	"printmaster/server/storage"
+)
+
+// xlsxSheetName is the name of the single worksheet in exported workbooks.
+const xlsxSheetName = "Report"
+
+var xlsxStaticParts = []struct {
+	name string
+	body string
+}{
+	{"[Content_Types].xml", `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types">
+<Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/>
+<Default Extension="xml" ContentType="application/xml"/>
+<Override PartName="/xl/workbook.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.sheet.main+xml"/>
+<Override PartName="/xl/worksheets/sheet1.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.worksheet+xml"/>
+</Types>`},
+	{"_rels/.rels", `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">
+<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="xl/workbook.xml"/>
+</Relationships>`},
+	{"xl/workbook.xml", `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships">
+<sheets><sheet name="` + xlsxSheetName + `" sheetId="1" r:id="rId1"/></sheets>
+</workbook>`},
+	{"xl/_rels/workbook.xml.rels", `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">
+<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet1.xml"/>
+</Relationships>`},
+}
+
+// FormatXLSX formats the result as a single-sheet Excel workbook. Columns
+// match the CSV output; numbers are written as numeric cells and everything
+// else as text.
+func (f *Formatter) FormatXLSX(result *GenerateResult) ([]byte, error) {
+	rows, columns := tabularRows(result)
+	if len(rows) == 0 && len(columns) == 0 {
+		if result.Summary == nil {
+			return nil, fmt.Errorf("no data to format")
+		}
+		// Summary-only reports become a single row, as in CSV output
+		for k := range result.Summary {
+			columns = append(columns, k)
+		}
+		sort.Strings(columns)
+		rows = []map[string]any{result.Summary}
+	}
+
+	var sheet bytes.Buffer
+	sheet.WriteString(`<?xml version="1.0" encoding="UTF-8" standalone="yes"?>` + "\n")
+	sheet.WriteString(`<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"><sheetData>`)
+
+	header := make([]any, len(columns))
+	for i, col := range columns {
+		header[i] = col
+	}
+	writeXLSXRow(&sheet, 1, header)
+	for i, row := range rows {
+		values := make([]any, len(columns))
+		for j, col := range columns {
+			values[j] = row[col]
+		}
+		writeXLSXRow(&sheet, i+2, values)
+	}
+	sheet.WriteString(`</sheetData></worksheet>`)
+
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for _, part := range xlsxStaticParts {
+		w, err := zw.Create(part.name)
+		if err != nil {
+			return nil, fmt.Errorf("create %s: %w", part.name, err)
+		}
+		if _, err := w.Write([]byte(part.body)); err != nil {
+			return nil, fmt.Errorf("write %s: %w", part.name, err)
+		}
+	}
+	w, err := zw.Create("xl/worksheets/sheet1.xml")
+	if err != nil {
+		return nil, fmt.Errorf("create sheet: %w", err)
+	}
+	if _, err := w.Write(sheet.Bytes()); err != nil {
+		return nil, fmt.Errorf("write sheet: %w", err)
+	}
+	if err := zw.Close(); err != nil {
+		return nil, fmt.Errorf("close workbook: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+func writeXLSXRow(buf *bytes.Buffer, rowNum int, values []any) {
+	fmt.Fprintf(buf, `<row r="%d">`, rowNum)
+	for i, v := range values {
+		ref := xlsxColumnName(i) + strconv.Itoa(rowNum)
+		if n, ok := toFloat(v); ok {
+			fmt.Fprintf(buf, `<c r="%s"><v>%s</v></c>`, ref, strconv.FormatFloat(n, 'f', -1, 64))
+			continue
+		}
+		text := formatValue(v)
+		if text == "" {
+			continue
+		}
+		fmt.Fprintf(buf, `<c r="%s" t="inlineStr"><is><t xml:space="preserve">`, ref)
+		xml.EscapeText(buf, []byte(text))
+		buf.WriteString(`</t></is></c>`)
+	}
+	buf.WriteString(`</row>`)
+}
+
+// xlsxColumnName converts a zero-based column index to its spreadsheet
+// letter (0 -> A, 25 -> Z, 26 -> AA).
+func xlsxColumnName(i int) string {
+	name := ""
+	for i >= 0 {
+		name = string(rune('A'+i%26)) + name
+		i = i/26 - 1
+	}
+	return name
+}
+
+// EncodeResultData converts formatted report output to the text stored in a
+// report run. Binary formats are base64 encoded.
+func EncodeResultData(format string, data []byte) string {
+	if binaryFormat(format) {
+		return base64.StdEncoding.EncodeToString(data)
+	}
+	return string(data)
+}
+
+// DecodeResultData reverses EncodeResultData.
+func DecodeResultData(format, stored string) ([]byte, error) {
+	if binaryFormat(format) {
+		return base64.StdEncoding.DecodeString(stored)
+	}
+	return []byte(stored), nil
+}
+
+func binaryFormat(format string) bool {
+	return format == storage.ReportFormatXLSX || format == storage.ReportFormatPDF
+}
@@ -0,0 +1,440 @@
+package reports
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"printmaster/server/storage"
+)
+
+// ---------- Toner Yield Reports ----------
+
+// Toner yield states.
+const (
+	YieldOK               = "ok"
+	YieldUnderperforming  = "underperforming"
+	YieldInsufficientData = "insufficient_data"
+	YieldNoBaseline       = "no_baseline"
+)
+
+// colorSupplies are measured against the color page counter when the device
+// reports one; every other supply uses the total page counter.
+var colorSupplies = map[string]bool{"cyan": true, "magenta": true, "yellow": true}
+
+// YieldOptions configures the toner yield report. It is read from the
+// report's OptionsJSON; rated yields come from the baseline library.
+type YieldOptions struct {
+	// UnderperformingPct flags groups whose average yield is below this
+	// percentage of the rated yield.
+	UnderperformingPct float64 `json:"underperforming_pct,omitempty"`
+	// MinCycles is the number of completed cartridges a group needs before
+	// it is classified.
+	MinCycles int `json:"min_cycles,omitempty"`
+	// ReplacementJump is the toner level rise (in percentage points) that
+	// counts as a cartridge replacement.
+	ReplacementJump float64 `json:"replacement_jump,omitempty"`
+	// MinConsumedPct ignores cycles in which less of the cartridge was
+	// observed being used, since extrapolating them is unreliable.
+	MinConsumedPct float64 `json:"min_consumed_pct,omitempty"`
+}
+
+// ParseYieldOptions decodes toner yield options and fills in defaults.
+func ParseYieldOptions(raw string) (*YieldOptions, error) {
+	opts := &YieldOptions{}
+	if strings.TrimSpace(raw) != "" {
+		if err := json.Unmarshal([]byte(raw), opts); err != nil {
+			return nil, fmt.Errorf("invalid toner yield options: %w", err)
+		}
+	}
+	if opts.UnderperformingPct <= 0 {
+		opts.UnderperformingPct = 85
+	}
+	if opts.MinCycles <= 0 {
+		opts.MinCycles = 2
+	}
+	if opts.ReplacementJump <= 0 {
+		opts.ReplacementJump = 30
+	}
+	if opts.MinConsumedPct <= 0 {
+		opts.MinConsumedPct = 20
+	}
+	return opts, nil
+}
+
+// MatchYieldBaseline finds the baseline for a device supply. Printer models
+// match exactly first and then by the longest contained entry. When several
+// cartridges are listed for the same model and supply, the one whose
+// cartridge model appears in the device's consumable descriptions wins.
+func MatchYieldBaseline(baselines []storage.YieldBaseline, model, supply string, consumables []string) (storage.YieldBaseline, bool) {
+	lower := strings.ToLower(strings.TrimSpace(model))
+	var candidates []storage.YieldBaseline
+	bestLen, exact := 0, false
+	for _, b := range baselines {
+		if !strings.EqualFold(b.Supply, supply) {
+			continue
+		}
+		k := strings.ToLower(strings.TrimSpace(b.PrinterModel))
+		if k == "" || !strings.Contains(lower, k) {
+			continue
+		}
+		isExact := k == lower
+		switch {
+		case exact && !isExact:
+			continue
+		case isExact && !exact, !exact && len(k) > bestLen:
+			candidates = candidates[:0]
+		case !exact && len(k) < bestLen:
+			continue
+		}
+		candidates = append(candidates, b)
+		exact, bestLen = isExact, len(k)
+	}
+	if len(candidates) == 0 {
+		return storage.YieldBaseline{}, false
+	}
+	for _, b := range candidates {
+		if b.CartridgeModel == "" {
+			continue
+		}
+		part := strings.ToLower(b.CartridgeModel)
+		for _, c := range consumables {
+			if strings.Contains(strings.ToLower(c), part) {
+				return b, true
+			}
+		}
+	}
+	return candidates[0], true
+}
+
+// tonerCycle is one observed cartridge lifetime ending in a replacement.
+type tonerCycle struct {
+	pages    int
+	consumed float64
+}
+
+// yieldPages extrapolates a cycle to the pages a full cartridge would print.
+func (c tonerCycle) yieldPages() float64 {
+	return float64(c.pages) * 100 / c.consumed
+}
+
+// tonerLevel converts a stored toner level to a percentage; negative values
+// are SNMP sentinels for unknown levels.
+func tonerLevel(raw interface{}) (float64, bool) {
+	var f float64
+	switch v := raw.(type) {
+	case float64:
+		f = v
+	case int:
+		f = float64(v)
+	case int64:
+		f = float64(v)
+	case json.Number:
+		n, err := v.Float64()
+		if err != nil {
+			return 0, false
+		}
+		f = n
+	default:
+		return 0, false
+	}
+	if f < 0 || math.IsNaN(f) {
+		return 0, false
+	}
+	return f, true
+}
+
+// tonerCycles detects cartridge replacements in a device's history and
+// returns the completed cycles per supply. A replacement is a level rise of
+// at least ReplacementJump points; the cycle before it is measured from the
+// first reading after the previous replacement to the last reading before
+// this one.
+func tonerCycles(history []*storage.MetricsSnapshot, opts *YieldOptions) map[string][]tonerCycle {
+	sorted := make([]*storage.MetricsSnapshot, 0, len(history))
+	supplies := make(map[string]bool)
+	for _, snap := range history {
+		if snap == nil {
+			continue
+		}
+		sorted = append(sorted, snap)
+		for key := range snap.TonerLevels {
+			supplies[strings.ToLower(key)] = true
+		}
+	}
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].Timestamp.Before(sorted[j].Timestamp) })
+
+	out := make(map[string][]tonerCycle)
+	for supply := range supplies {
+		var start, prev *storage.MetricsSnapshot
+		var startLevel, prevLevel float64
+		for _, snap := range sorted {
+			level, ok := snapshotTonerLevel(snap, supply)
+			if !ok {
+				continue
+			}
+			if start == nil {
+				start, startLevel = snap, level
+			} else if level-prevLevel >= opts.ReplacementJump {
+				consumed := startLevel - prevLevel
+				pages := SupplyCounter(prev, supply) - SupplyCounter(start, supply)
+				// Counter resets and barely-used cartridges can't be extrapolated
+				if consumed >= opts.MinConsumedPct && pages > 0 {
+					out[supply] = append(out[supply], tonerCycle{pages: pages, consumed: consumed})
+				}
+				start, startLevel = snap, level
+			}
+			prev, prevLevel = snap, level
+		}
+	}
+	return out
+}
+
+func snapshotTonerLevel(snap *storage.MetricsSnapshot, supply string) (float64, bool) {
+	for key, raw := range snap.TonerLevels {
+		if strings.EqualFold(key, supply) {
+			return tonerLevel(raw)
+		}
+	}
+	return 0, false
+}
+
+// SupplyCounter returns the page counter a supply is measured against: the
+// color counter for cyan, magenta and yellow when reported, else the total.
+func SupplyCounter(snap *storage.MetricsSnapshot, supply string) int {
+	if colorSupplies[supply] && snap.ColorPages > 0 {
+		return snap.ColorPages
+	}
+	return snap.PageCount
+}
+
+// yieldGroup accumulates cycles for one model/supply/cartridge/supplier.
+type yieldGroup struct {
+	model     string
+	supply    string
+	cartridge string
+	supplier  string
+	rated     int
+	devices   map[string]bool
+	yields    []float64
+}
+
+func (g *Generator) generateTonerYield(ctx context.Context, params GenerateParams) (*GenerateResult, error) {
+	opts, err := ParseYieldOptions(params.Report.OptionsJSON)
+	if err != nil {
+		return nil, err
+	}
+
+	baselines, err := g.store.ListYieldBaselines(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("list yield baselines: %w", err)
+	}
+	devices, err := g.store.ListAllDevices(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("list devices: %w", err)
+	}
+	agents, err := g.store.ListAgents(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("list agents: %w", err)
+	}
+	if devices, err = g.filterDevices(ctx, devices, params.Report); err != nil {
+		return nil, err
+	}
+
+	agentTenant := make(map[string]string, len(agents))
+	for _, a := range agents {
+		agentTenant[a.AgentID] = a.TenantID
+	}
+	tenantFilter := make(map[string]bool)
+	for _, id := range params.Report.TenantIDs {
+		tenantFilter[id] = true
+	}
+
+	end := params.EndTime
+	if end.IsZero() {
+		end = time.Now().UTC()
+	}
+	start := params.StartTime
+	if start.IsZero() {
+		// Cartridges last months; a year gives several cycles per device
+		start = end.AddDate(-1, 0, 0)
+	}
+
+	groups := make(map[string]*yieldGroup)
+	var order []string
+	var uncataloged []string
+	seenUncataloged := make(map[string]bool)
+	totalCycles := 0
+
+	for _, d := range devices {
+		if d == nil || d.Serial == "" {
+			continue
+		}
+		if len(tenantFilter) > 0 && !tenantFilter[agentTenant[d.AgentID]] {
+			continue
+		}
+		history, err := g.store.GetMetricsHistory(ctx, d.Serial, start)
+		if err != nil {
+			continue
+		}
+		inPeriod := history[:0:0]
+		for _, snap := range history {
+			if snap != nil && !snap.Timestamp.After(end) {
+				inPeriod = append(inPeriod, snap)
+			}
+		}
+
+		for supply, cycles := range tonerCycles(inPeriod, opts) {
+			if len(cycles) == 0 {
+				continue
+			}
+			group := &yieldGroup{model: d.Model, supply: supply}
+			if b, ok := MatchYieldBaseline(baselines, d.Model, supply, d.Consumables); ok {
+				group.model, group.cartridge, group.supplier, group.rated = b.PrinterModel, b.CartridgeModel, b.Supplier, b.RatedYield
+			} else if d.Model != "" && !seenUncataloged[d.Model] {
+				seenUncataloged[d.Model] = true
+				uncataloged = append(uncataloged, d.Model)
+			}
+			key := strings.ToLower(strings.Join([]string{group.model, group.supply, group.cartridge, group.supplier}, "\x00"))
+			existing, ok := groups[key]
+			if !ok {
+				group.devices = make(map[string]bool)
+				groups[key] = group
+				order = append(order, key)
+				existing = group
+			}
+			existing.devices[d.Serial] = true
+			for _, c := range cycles {
+				existing.yields = append(existing.yields, c.yieldPages())
+			}
+			totalCycles += len(cycles)
+		}
+	}
+
+	type supplierTotals struct {
+		cycles int
+		actual float64
+		rated  float64
+	}
+	bySupplier := make(map[string]*supplierTotals)
+	statusCounts := map[string]int{YieldOK: 0, YieldUnderperforming: 0, YieldInsufficientData: 0, YieldNoBaseline: 0}
+
+	rows := make([]map[string]any, 0, len(order))
+	for _, key := range order {
+		grp := groups[key]
+		var sum float64
+		minYield, maxYield := math.Inf(1), 0.0
+		for _, y := range grp.yields {
+			sum += y
+			minYield = math.Min(minYield, y)
+			maxYield = math.Max(maxYield, y)
+		}
+		avg := int(math.Round(sum / float64(len(grp.yields))))
+		row := map[string]any{
+			"printer_model":   grp.model,
+			"supply":          grp.supply,
+			"cartridge_model": grp.cartridge,
+			"supplier":        grp.supplier,
+			"devices":         len(grp.devices),
+			"cycles":          len(grp.yields),
+			"avg_yield":       avg,
+			"min_yield":       int(math.Round(minYield)),
+			"max_yield":       int(math.Round(maxYield)),
+		}
+		status := YieldNoBaseline
+		if grp.rated > 0 {
+			pct := percentOf(avg, grp.rated)
+			row["rated_yield"] = grp.rated
+			row["yield_pct"] = pct
+			switch {
+			case len(grp.yields) < opts.MinCycles:
+				status = YieldInsufficientData
+			case pct < opts.UnderperformingPct:
+				status = YieldUnderperforming
+			default:
+				status = YieldOK
+			}
+			supplier := grp.supplier
+			if supplier == "" {
+				supplier = "unspecified"
+			}
+			totals := bySupplier[supplier]
+			if totals == nil {
+				totals = &supplierTotals{}
+				bySupplier[supplier] = totals
+			}
+			totals.cycles += len(grp.yields)
+			totals.actual += sum
+			totals.rated += float64(grp.rated * len(grp.yields))
+		}
+		row["status"] = status
+		statusCounts[status]++
+		rows = append(rows, row)
+	}
+
+	// Worst performers first; groups without a baseline last
+	sort.SliceStable(rows, func(i, j int) bool {
+		pi, iok := rows[i]["yield_pct"].(float64)
+		pj, jok := rows[j]["yield_pct"].(float64)
+		if iok != jok {
+			return iok
+		}
+		return pi < pj
+	})
+	if params.Report.Limit > 0 && len(rows) > params.Report.Limit {
+		rows = rows[:params.Report.Limit]
+	}
+	sort.Strings(uncataloged)
+
+	suppliers := make([]map[string]any, 0, len(bySupplier))
+	for name, totals := range bySupplier {
+		pct := percentOf(int(math.Round(totals.actual)), int(math.Round(totals.rated)))
+		status := YieldOK
+		switch {
+		case totals.cycles < opts.MinCycles:
+			status = YieldInsufficientData
+		case pct < opts.UnderperformingPct:
+			status = YieldUnderperforming
+		}
+		suppliers = append(suppliers, map[string]any{
+			"supplier":  name,
+			"cycles":    totals.cycles,
+			"yield_pct": pct,
+			"status":    status,
+		})
+	}
+	sort.Slice(suppliers, func(i, j int) bool {
+		return suppliers[i]["yield_pct"].(float64) < suppliers[j]["yield_pct"].(float64)
+	})
+
+	columns := []string{
+		"printer_model", "supply", "cartridge_model", "supplier", "devices", "cycles",
+		"rated_yield", "avg_yield", "min_yield", "max_yield", "yield_pct", "status",
+	}
+
+	return &GenerateResult{
+		Rows:     rows,
+		Columns:  columns,
+		RowCount: len(rows),
+		Summary: map[string]any{
+			"period_start":              start,
+			"period_end":                end,
+			"replacements":              totalCycles,
+			"groups":                    len(order),
+			"underperforming_groups":    statusCounts[YieldUnderperforming],
+			"ok_groups":                 statusCounts[YieldOK],
+			"insufficient_data_groups":  statusCounts[YieldInsufficientData],
+			"groups_without_baseline":   statusCounts[YieldNoBaseline],
+			"suppliers":                 suppliers,
+			"uncataloged_models":        uncataloged,
+			"underperforming_threshold": opts.UnderperformingPct,
+		},
+		Metadata: map[string]string{
+			"report_type": string(params.Report.Type),
+			"generated":   time.Now().UTC().Format(time.RFC3339),
+		},
+	}, nil
+}
@@ -0,0 +1,150 @@
+package reports
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"printmaster/server/storage"
+)
+
+// tonerSnap returns a snapshot with the given counters and black/cyan levels.
+func tonerSnap(serial string, at time.Time, pages, colorPages int, black, cyan float64) *storage.MetricsSnapshot {
+	return &storage.MetricsSnapshot{
+		Serial:      serial,
+		Timestamp:   at,
+		PageCount:   pages,
+		ColorPages:  colorPages,
+		TonerLevels: map[string]interface{}{"black": black, "cyan": cyan},
+	}
+}
+
+func TestTonerCycles(t *testing.T) {
+	t.Parallel()
+	opts, _ := ParseYieldOptions("")
+	base := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	history := []*storage.MetricsSnapshot{
+		tonerSnap("A", base, 0, 0, 100, 80),
+		tonerSnap("A", base.Add(48*time.Hour), 4000, 500, 50, 60),
+		// Black replaced; cyan unknown (-2) for one reading
+		tonerSnap("A", base.Add(72*time.Hour), 4100, 600, 100, -2),
+		tonerSnap("A", base.Add(96*time.Hour), 6000, 1000, 90, 40),
+		// Cyan replaced after 40 points and 1000 color pages
+		tonerSnap("A", base.Add(120*time.Hour), 6100, 1100, 89, 100),
+		// Toner top-up of 10 points is not a replacement
+		tonerSnap("A", base.Add(144*time.Hour), 6200, 1200, 99, 98),
+	}
+
+	cycles := tonerCycles(history, opts)
+	if got := cycles["black"]; len(got) != 1 || got[0].pages != 4000 || got[0].yieldPages() != 8000 {
+		t.Fatalf("black cycles = %+v", got)
+	}
+	if got := cycles["cyan"]; len(got) != 1 || got[0].pages != 1000 || got[0].yieldPages() != 2500 {
+		t.Fatalf("cyan cycles = %+v", got)
+	}
+}
+
+func TestMatchYieldBaseline(t *testing.T) {
+	t.Parallel()
+	baselines := []storage.YieldBaseline{
+		{PrinterModel: "LaserJet", Supply: "black", CartridgeModel: "GENERIC", RatedYield: 1000},
+		{PrinterModel: "LaserJet M404", Supply: "black", CartridgeModel: "CF258A", RatedYield: 3000},
+		{PrinterModel: "LaserJet M404", Supply: "black", CartridgeModel: "CF258X", RatedYield: 10000},
+		{PrinterModel: "LaserJet M404", Supply: "cyan", RatedYield: 2000},
+	}
+
+	b, ok := MatchYieldBaseline(baselines, "HP LaserJet M404dn", "black", []string{"Black Cartridge HP CF258X"})
+	if !ok || b.CartridgeModel != "CF258X" {
+		t.Fatalf("expected CF258X, got %+v (%v)", b, ok)
+	}
+	// No consumable hint: first entry for the most specific model
+	if b, _ := MatchYieldBaseline(baselines, "HP LaserJet M404dn", "black", nil); b.CartridgeModel != "CF258A" {
+		t.Fatalf("expected CF258A, got %+v", b)
+	}
+	if b, _ := MatchYieldBaseline(baselines, "LaserJet P2055", "black", nil); b.CartridgeModel != "GENERIC" {
+		t.Fatalf("expected GENERIC, got %+v", b)
+	}
+	if _, ok := MatchYieldBaseline(baselines, "ImageRunner C3530", "black", nil); ok {
+		t.Fatal("expected no baseline for an uncataloged model")
+	}
+}
+
+func TestGenerator_TonerYield(t *testing.T) {
+	t.Parallel()
+
+	store := newMockGeneratorStore()
+	store.baselines = []storage.YieldBaseline{
+		{PrinterModel: "LaserJet M404", Supply: "black", CartridgeModel: "CF258X", Supplier: "HP", RatedYield: 10000},
+		{PrinterModel: "LaserJet M404", Supply: "black", CartridgeModel: "R-58X", Supplier: "Acme Reman", RatedYield: 10000},
+	}
+	oem := newTestDevice("OEM", "HP LaserJet M404dn", "10.0.0.1", "agent-1")
+	oem.Consumables = []string{"Black Cartridge CF258X"}
+	reman := newTestDevice("REMAN", "HP LaserJet M404n", "10.0.0.2", "agent-1")
+	reman.Consumables = []string{"Black Cartridge R-58X"}
+	other := newTestDevice("OTHER", "ImageRunner C3530", "10.0.0.3", "agent-1")
+	store.devices = []*storage.Device{oem, reman, other}
+
+	end := time.Now().UTC()
+	day := func(n int) time.Time { return end.AddDate(0, 0, -90+n) }
+	// OEM: 8000 pages on half a cartridge, then 10000 on a full one
+	store.metricsHist["OEM"] = []*storage.MetricsSnapshot{
+		tonerSnap("OEM", day(0), 0, 0, 100, -1),
+		tonerSnap("OEM", day(10), 4000, 0, 50, -1),
+		tonerSnap("OEM", day(11), 4000, 0, 100, -1),
+		tonerSnap("OEM", day(40), 14000, 0, 0, -1),
+		tonerSnap("OEM", day(41), 14000, 0, 100, -1),
+	}
+	// Remanufactured cartridges run out after about 6000 pages
+	store.metricsHist["REMAN"] = []*storage.MetricsSnapshot{
+		tonerSnap("REMAN", day(0), 0, 0, 100, -1),
+		tonerSnap("REMAN", day(20), 6000, 0, 0, -1),
+		tonerSnap("REMAN", day(21), 6000, 0, 100, -1),
+		tonerSnap("REMAN", day(40), 12000, 0, 0, -1),
+		tonerSnap("REMAN", day(41), 12000, 0, 100, -1),
+	}
+	store.metricsHist["OTHER"] = []*storage.MetricsSnapshot{
+		tonerSnap("OTHER", day(0), 0, 0, 90, -1),
+		tonerSnap("OTHER", day(30), 9000, 0, 10, -1),
+		tonerSnap("OTHER", day(31), 9000, 0, 100, -1),
+	}
+
+	gen := NewGenerator(store)
+	result, err := gen.Generate(context.Background(), GenerateParams{
+		Report:    &storage.ReportDefinition{Type: storage.ReportTypeTonerYield},
+		StartTime: end.AddDate(0, 0, -120),
+		EndTime:   end,
+	})
+	if err != nil {
+		t.Fatalf("Generate failed: %v", err)
+	}
+	if len(result.Rows) != 3 {
+		t.Fatalf("expected 3 rows, got %d: %+v", len(result.Rows), result.Rows)
+	}
+
+	reman0 := result.Rows[0]
+	if reman0["supplier"] != "Acme Reman" || reman0["yield_pct"] != 60.0 || reman0["status"] != YieldUnderperforming || reman0["cycles"] != 2 {
+		t.Fatalf("unexpected remanufactured row: %+v", reman0)
+	}
+	oemRow := result.Rows[1]
+	if oemRow["cartridge_model"] != "CF258X" || oemRow["avg_yield"] != 9000 || oemRow["status"] != YieldOK {
+		t.Fatalf("unexpected OEM row: %+v", oemRow)
+	}
+	if result.Rows[2]["status"] != YieldNoBaseline || result.Rows[2]["printer_model"] != "ImageRunner C3530" {
+		t.Fatalf("unexpected uncataloged row: %+v", result.Rows[2])
+	}
+
+	suppliers := result.Summary["suppliers"].([]map[string]any)
+	if len(suppliers) != 2 || suppliers[0]["supplier"] != "Acme Reman" || suppliers[0]["status"] != YieldUnderperforming {
+		t.Fatalf("unexpected supplier summary: %+v", suppliers)
+	}
+	if result.Summary["replacements"] != 5 || result.Summary["underperforming_groups"] != 1 {
+		t.Fatalf("unexpected summary: %+v", result.Summary)
+	}
+	if models := result.Summary["uncataloged_models"].([]string); len(models) != 1 || models[0] != "ImageRunner C3530" {
+		t.Fatalf("unexpected uncataloged models: %v", models)
+	}
+
+	if _, err := ParseYieldOptions(`{"min_cycles":"two"}`); err == nil {
+		t.Fatal("expected invalid options to be rejected")
+	}
+}
@@ -0,0 +1,300 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	alertsapi "printmaster/server/alerts"
+	authz "printmaster/server/authz"
+	"printmaster/server/scanmail"
+	"printmaster/server/storage"
+)
+
+const (
+	scanPathDefaultLimit = 100
+	scanPathMaxLimit     = 1000
+	// Serials shorter than this are too likely to match an unrelated word
+	scanPathMinSerialLen = 5
+)
+
+// startScanMail starts the SMTP sink that receives scan-to-email tests.
+func startScanMail(cfg ScanMailConfig) (*scanmail.Server, error) {
+	networks, err := scanmail.ParseNetworks(cfg.AllowedNetworks)
+	if err != nil {
+		return nil, fmt.Errorf("scan mail allowed_networks: %w", err)
+	}
+	srv := &scanmail.Server{
+		Addr:            cfg.ListenAddr,
+		Hostname:        cfg.Hostname,
+		AllowedNetworks: networks,
+		Handler:         handleScanMail,
+	}
+	if cfg.MaxMessageMB > 0 {
+		srv.MaxMessageBytes = int64(cfg.MaxMessageMB) << 20
+	}
+	if err := srv.ListenAndServe(); err != nil {
+		return nil, err
+	}
+	logInfo("Scan mail sink listening", "addr", srv.ListenAddr().String(), "allowed_networks", len(networks))
+	return srv, nil
+}
+
+// handleAlertReply acknowledges the alert a reply to an alert email names.
+// The signed subject tag authorizes it; the sender is recorded for audit.
+func handleAlertReply(ctx context.Context, alertID int64, sender, remoteIP string) error {
+	actor := "email reply"
+	if sender != "" {
+		actor = "email reply from " + sender
+	}
+	alert, err := alertsapi.ApplyAlertAction(ctx, serverStore, alertID, alertsapi.AlertLinkActionAcknowledge, 0, actor)
+	if errors.Is(err, alertsapi.ErrAlertNotFound) || errors.Is(err, alertsapi.ErrAlertClosed) {
+		logInfo("Alert reply ignored", "alert_id", alertID, "sender", sender, "reason", err)
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("acknowledge alert %d: %w", alertID, err)
+	}
+	logInfo("Alert acknowledged by email reply", "alert_id", alertID, "sender", sender)
+	logAuditEntry(ctx, &storage.AuditEntry{
+		ActorType:  storage.AuditActorSystem,
+		ActorID:    "email-reply",
+		ActorName:  actor,
+		Action:     "alert.acknowledge",
+		TargetType: "alert",
+		TargetID:   strconv.FormatInt(alertID, 10),
+		TenantID:   alert.TenantID,
+		Details:    "Acknowledged alert via email reply",
+		Metadata:   map[string]interface{}{"via": "email_reply", "sender": sender},
+		IPAddress:  remoteIP,
+	})
+	return nil
+}
+
+// handleScanMail records a received test scan and ties it to a device.
+// Replies to alert emails are recognized by their subject tag and
+// acknowledge the alert instead.
+func handleScanMail(ctx context.Context, env *scanmail.Envelope) error {
+	msg, err := scanmail.ParseMessage(env.Data)
+	if err != nil {
+		// Still record it: the MFP reached us, even if the message is odd
+		logWarn("Scan mail: could not fully parse message", "remote_ip", env.RemoteIP, "error", err)
+		if msg == nil {
+			msg = &scanmail.Message{}
+		}
+	}
+
+	if alertID, ok := alertNotifier.AlertIDFromReply(msg.Subject); ok {
+		sender := env.MailFrom
+		if sender == "" {
+			sender = msg.From
+		}
+		return handleAlertReply(ctx, alertID, sender, env.RemoteIP)
+	}
+
+	check := &storage.ScanPathCheck{
+		Sender:     env.MailFrom,
+		Recipients: env.RcptTo,
+		Subject:    msg.Subject,
+		SourceIP:   env.RemoteIP,
+		ReceivedAt: env.ReceivedAt,
+	}
+	if check.Sender == "" {
+		check.Sender = msg.From
+	}
+	for _, a := range msg.Attachments {
+		check.Attachments = append(check.Attachments, storage.ScanPathAttachment(a))
+		check.AttachmentBytes += a.Size
+	}
+	check.AttachmentCount = len(check.Attachments)
+
+	devices, err := serverStore.ListAllDevices(ctx)
+	if err != nil {
+		return fmt.Errorf("list devices: %w", err)
+	}
+	if device, method := matchScanMailDevice(devices, env, msg); device != nil {
+		check.Serial = device.Serial
+		check.AgentID = device.AgentID
+		check.MatchMethod = method
+		check.Verified = check.AttachmentCount > 0
+	}
+
+	if err := serverStore.SaveScanPathCheck(ctx, check); err != nil {
+		return fmt.Errorf("save scan path check: %w", err)
+	}
+	logInfo("Scan mail received", "serial", check.Serial, "match", check.MatchMethod, "verified", check.Verified,
+		"remote_ip", env.RemoteIP, "attachments", check.AttachmentCount)
+	sseHub.Broadcast(SSEEvent{
+		Type: "scan_path_check",
+		Data: map[string]interface{}{
+			"id":       check.ID,
+			"serial":   check.Serial,
+			"agent_id": check.AgentID,
+			"verified": check.Verified,
+		},
+	})
+	return nil
+}
+
+// matchScanMailDevice finds the device that sent a test scan. Serials in the
+// subject or attachment names are the most reliable, then a sender named
+// after the device, then the source IP. A hint that fits several devices is
+// ignored.
+func matchScanMailDevice(devices []*storage.Device, env *scanmail.Envelope, msg *scanmail.Message) (*storage.Device, string) {
+	tokens := make(map[string]bool)
+	addTokens := func(s string) {
+		for _, t := range strings.FieldsFunc(s, func(r rune) bool {
+			return !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '-'
+		}) {
+			tokens[strings.ToUpper(t)] = true
+		}
+	}
+	addTokens(msg.Subject)
+	for _, a := range msg.Attachments {
+		addTokens(a.Filename)
+	}
+	if d := uniqueDevice(devices, func(d *storage.Device) bool {
+		serial := strings.ToUpper(strings.TrimSpace(d.Serial))
+		return len(serial) >= scanPathMinSerialLen && tokens[serial]
+	}); d != nil {
+		return d, storage.ScanPathMatchSubjectSerial
+	}
+
+	var senders []string
+	for _, addr := range []string{env.MailFrom, msg.From} {
+		if local, _, ok := strings.Cut(addr, "@"); ok && local != "" {
+			senders = append(senders, strings.ToLower(local))
+		}
+	}
+	if len(senders) > 0 {
+		if d := uniqueDevice(devices, func(d *storage.Device) bool {
+			host, _, _ := strings.Cut(strings.ToLower(strings.TrimSpace(d.Hostname)), ".")
+			serial := strings.ToLower(strings.TrimSpace(d.Serial))
+			for _, s := range senders {
+				if (serial != "" && s == serial) || (host != "" && s == host) {
+					return true
+				}
+			}
+			return false
+		}); d != nil {
+			return d, storage.ScanPathMatchSender
+		}
+	}
+
+	if env.RemoteIP != "" {
+		if d := uniqueDevice(devices, func(d *storage.Device) bool { return d.IP == env.RemoteIP }); d != nil {
+			return d, storage.ScanPathMatchSourceIP
+		}
+	}
+	return nil, ""
+}
+
+// uniqueDevice returns the only device matching fn, or nil if none or
+// several match.
+func uniqueDevice(devices []*storage.Device, fn func(*storage.Device) bool) *storage.Device {
+	var found *storage.Device
+	for _, d := range devices {
+		if d == nil || !fn(d) {
+			continue
+		}
+		if found != nil {
+			return nil
+		}
+		found = d
+	}
+	return found
+}
+
+// handleScanPathChecks serves GET /api/v1/scan-path?serial=&unmatched=&limit=
+// with received test scans, newest first. Messages not tied to a device may
+// come from any tenant's network, so only unscoped users see them.
+func handleScanPathChecks(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "GET only", http.StatusMethodNotAllowed)
+		return
+	}
+	if !authorizeOrReject(w, r, authz.ActionDevicesRead, authz.ResourceRef{}) {
+		return
+	}
+	scope, ok := tenantScope(getPrincipal(r))
+	if !ok {
+		http.Error(w, "forbidden", http.StatusForbidden)
+		return
+	}
+
+	q := r.URL.Query()
+	filter := storage.ScanPathCheckFilter{
+		Serial:    strings.TrimSpace(q.Get("serial")),
+		Unmatched: q.Get("unmatched") == "true" || q.Get("unmatched") == "1",
+		Limit:     scanPathDefaultLimit,
+	}
+	if v := q.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			http.Error(w, "invalid limit", http.StatusBadRequest)
+			return
+		}
+		filter.Limit = min(n, scanPathMaxLimit)
+	}
+
+	ctx := r.Context()
+	checks := []*storage.ScanPathCheck{}
+	if scope != nil {
+		if filter.Unmatched {
+			http.Error(w, "forbidden", http.StatusForbidden)
+			return
+		}
+		agents, err := serverStore.ListAgents(ctx)
+		if err != nil {
+			logError("Scan path: failed to list agents", "error", err)
+			http.Error(w, "failed to load scan path checks", http.StatusInternalServerError)
+			return
+		}
+		for _, a := range agents {
+			if a != nil && tenantAllowed(scope, a.TenantID) {
+				filter.AgentIDs = append(filter.AgentIDs, a.AgentID)
+			}
+		}
+		if len(filter.AgentIDs) == 0 {
+			writeScanPathChecks(w, checks)
+			return
+		}
+	}
+
+	found, err := serverStore.ListScanPathChecks(ctx, filter)
+	if err != nil {
+		logError("Scan path: failed to list checks", "error", err)
+		http.Error(w, "failed to load scan path checks", http.StatusInternalServerError)
+		return
+	}
+	if found != nil {
+		checks = found
+	}
+	writeScanPathChecks(w, checks)
+}
+
+func writeScanPathChecks(w http.ResponseWriter, checks []*storage.ScanPathCheck) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"checks": checks})
+}
+
+// pruneScanPathChecks drops received test scans older than retentionDays.
+func pruneScanPathChecks(ctx context.Context, retentionDays int) {
+	if retentionDays <= 0 {
+		return
+	}
+	removed, err := serverStore.DeleteScanPathChecksBefore(ctx, time.Now().AddDate(0, 0, -retentionDays))
+	if err != nil {
+		logWarn("Failed to prune scan path checks", "error", err)
+		return
+	}
+	if removed > 0 {
+		logInfo("Pruned old scan path checks", "count", removed, "retention_days", retentionDays)
+	}
+}
@@ -0,0 +1,164 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	alertsapi "printmaster/server/alerts"
+	"printmaster/server/scanmail"
+	"printmaster/server/storage"
+)
+
+func TestMatchScanMailDevice(t *testing.T) {
+	t.Parallel()
+	dev := func(serial, ip, host string) *storage.Device {
+		d := &storage.Device{AgentID: "agent-a"}
+		d.Serial, d.IP, d.Hostname = serial, ip, host
+		return d
+	}
+	devices := []*storage.Device{
+		dev("CNB1234567", "10.0.0.5", "lobby-mfp.corp.local"),
+		dev("XYZ9876543", "10.0.0.6", ""),
+		dev("ABC", "10.0.0.7", ""),
+		dev("DUP0000001", "10.0.0.8", ""),
+		dev("DUP0000002", "10.0.0.8", ""),
+	}
+	cases := []struct {
+		name       string
+		env        scanmail.Envelope
+		msg        scanmail.Message
+		wantSerial string
+		wantMethod string
+	}{
+		{"serial in subject", scanmail.Envelope{RemoteIP: "10.0.0.6"}, scanmail.Message{Subject: "Scan from cnb1234567"}, "CNB1234567", storage.ScanPathMatchSubjectSerial},
+		{"serial in attachment", scanmail.Envelope{}, scanmail.Message{Attachments: []scanmail.Attachment{{Filename: "XYZ9876543_0001.pdf"}}}, "XYZ9876543", storage.ScanPathMatchSubjectSerial},
+		{"sender hostname", scanmail.Envelope{MailFrom: "Lobby-MFP@scans.local"}, scanmail.Message{Subject: "Scanned image"}, "CNB1234567", storage.ScanPathMatchSender},
+		{"sender serial", scanmail.Envelope{MailFrom: "noreply@x"}, scanmail.Message{From: "xyz9876543@x"}, "XYZ9876543", storage.ScanPathMatchSender},
+		{"source ip", scanmail.Envelope{RemoteIP: "10.0.0.6"}, scanmail.Message{Subject: "Scan"}, "XYZ9876543", storage.ScanPathMatchSourceIP},
+		{"short serial ignored", scanmail.Envelope{}, scanmail.Message{Subject: "ABC test"}, "", ""},
+		{"ambiguous ip", scanmail.Envelope{RemoteIP: "10.0.0.8"}, scanmail.Message{}, "", ""},
+		{"no hints", scanmail.Envelope{RemoteIP: "192.168.1.1", MailFrom: "a@b"}, scanmail.Message{Subject: "hello"}, "", ""},
+	}
+	for _, tc := range cases {
+		d, method := matchScanMailDevice(devices, &tc.env, &tc.msg)
+		serial := ""
+		if d != nil {
+			serial = d.Serial
+		}
+		if serial != tc.wantSerial || method != tc.wantMethod {
+			t.Errorf("%s: got %q/%q, want %q/%q", tc.name, serial, method, tc.wantSerial, tc.wantMethod)
+		}
+	}
+}
+
+func TestHandleScanMailAndList(t *testing.T) {
+	store := SetupTestStore(t)
+	ctx := context.Background()
+	now := time.Now()
+
+	for _, tn := range []*storage.Tenant{{ID: "tenant-a", Name: "Acme"}, {ID: "tenant-b", Name: "Globex"}} {
+		if err := store.CreateTenant(ctx, tn); err != nil {
+			t.Fatalf("CreateTenant: %v", err)
+		}
+	}
+	for _, a := range []*storage.Agent{
+		{AgentID: "agent-a", Hostname: "acme-pc", Token: "ta", TenantID: "tenant-a", Status: "active", RegisteredAt: now, LastSeen: now},
+		{AgentID: "agent-b", Hostname: "globex-pc", Token: "tb", TenantID: "tenant-b", Status: "active", RegisteredAt: now, LastSeen: now},
+	} {
+		if err := store.RegisterAgent(ctx, a); err != nil {
+			t.Fatalf("RegisterAgent: %v", err)
+		}
+	}
+	dev := &storage.Device{AgentID: "agent-a"}
+	dev.Serial, dev.IP, dev.LastSeen = "CNB1234567", "10.0.0.5", now
+	if err := store.UpsertDevice(ctx, dev); err != nil {
+		t.Fatalf("UpsertDevice: %v", err)
+	}
+
+	scan := "From: mfp@example.com\r\nSubject: Scan CNB1234567\r\nContent-Type: multipart/mixed; boundary=b\r\n\r\n" +
+		"--b\r\nContent-Type: application/pdf\r\nContent-Disposition: attachment; filename=scan.pdf\r\n\r\n%PDF-1.4\r\n--b--\r\n"
+	for _, env := range []*scanmail.Envelope{
+		{RemoteIP: "10.0.0.5", MailFrom: "mfp@example.com", RcptTo: []string{"scans@pm"}, Data: []byte(scan), ReceivedAt: now},
+		{RemoteIP: "10.9.9.9", MailFrom: "x@y", RcptTo: []string{"scans@pm"}, Data: []byte("Subject: hi\r\n\r\nbody\r\n"), ReceivedAt: now},
+	} {
+		if err := handleScanMail(ctx, env); err != nil {
+			t.Fatalf("handleScanMail: %v", err)
+		}
+	}
+
+	latest, err := store.GetLatestScanPathCheck(ctx, "CNB1234567")
+	if err != nil || latest == nil || !latest.Verified || latest.AgentID != "agent-a" || latest.AttachmentCount != 1 {
+		t.Fatalf("latest check = %+v, %v", latest, err)
+	}
+
+	fetch := func(user *storage.User, query string) ([]*storage.ScanPathCheck, int) {
+		t.Helper()
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/scan-path?"+query, nil)
+		rr := httptest.NewRecorder()
+		handleScanPathChecks(rr, InjectTestUser(req, user))
+		var resp struct {
+			Checks []*storage.ScanPathCheck `json:"checks"`
+		}
+		if rr.Code == http.StatusOK {
+			if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil {
+				t.Fatalf("decode: %v", err)
+			}
+		}
+		return resp.Checks, rr.Code
+	}
+
+	if checks, code := fetch(NewTestAdminUser(), ""); code != http.StatusOK || len(checks) != 2 {
+		t.Errorf("admin list: code=%d checks=%d", code, len(checks))
+	}
+	if checks, code := fetch(NewTestAdminUser(), "unmatched=true"); code != http.StatusOK || len(checks) != 1 || checks[0].Serial != "" {
+		t.Errorf("admin unmatched: code=%d %+v", code, checks)
+	}
+	if checks, code := fetch(NewTestUser(storage.RoleViewer, "tenant-a"), ""); code != http.StatusOK || len(checks) != 1 || checks[0].Serial != "CNB1234567" {
+		t.Errorf("tenant-a list: code=%d %+v", code, checks)
+	}
+	if checks, code := fetch(NewTestUser(storage.RoleViewer, "tenant-b"), ""); code != http.StatusOK || len(checks) != 0 {
+		t.Errorf("tenant-b list: code=%d %+v", code, checks)
+	}
+	if _, code := fetch(NewTestUser(storage.RoleViewer, "tenant-a"), "unmatched=true"); code != http.StatusForbidden {
+		t.Errorf("scoped unmatched: code=%d, want 403", code)
+	}
+}
+
+func TestHandleScanMailAlertReply(t *testing.T) {
+	store := SetupTestStore(t)
+	ctx := context.Background()
+
+	key := []byte("0123456789abcdef0123456789abcdef")
+	prev := alertNotifier
+	alertNotifier = alertsapi.NewNotifier(store, alertsapi.NotifierConfig{ActionLinkKey: key, ReplyTo: "alerts@pm.example.com"})
+	t.Cleanup(func() { alertNotifier = prev })
+
+	id, err := store.CreateAlert(ctx, &storage.Alert{
+		Type: storage.AlertTypeSupplyLow, Severity: storage.AlertSeverityWarning, Scope: storage.AlertScopeDevice,
+		Status: storage.AlertStatusActive, DeviceSerial: "CNB1234567", Title: "Toner low", TriggeredAt: time.Now().UTC(),
+	})
+	if err != nil {
+		t.Fatalf("CreateAlert: %v", err)
+	}
+
+	reply := "From: tech@example.com\r\nSubject: RE: [WARNING] Toner low " + alertsapi.AlertReplyTag(key, id) + "\r\n\r\nOn it.\r\n"
+	env := &scanmail.Envelope{RemoteIP: "10.0.0.9", MailFrom: "tech@example.com", Data: []byte(reply), ReceivedAt: time.Now()}
+	if err := handleScanMail(ctx, env); err != nil {
+		t.Fatalf("handleScanMail: %v", err)
+	}
+
+	got, err := store.GetAlert(ctx, id)
+	if err != nil {
+		t.Fatalf("GetAlert: %v", err)
+	}
+	if got.Status != storage.AlertStatusAcknowledged || got.AcknowledgedBy != "email reply from tech@example.com" {
+		t.Fatalf("alert not acknowledged by reply: status %q by %q", got.Status, got.AcknowledgedBy)
+	}
+	if checks, err := store.ListScanPathChecks(ctx, storage.ScanPathCheckFilter{}); err != nil || len(checks) != 0 {
+		t.Errorf("reply should not be recorded as a scan: %d checks, %v", len(checks), err)
+	}
+}
@@ -0,0 +1,118 @@
+package scanmail
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/mail"
+	"strings"
+)
+
+// maxPartDepth bounds nested multipart parsing.
+const maxPartDepth = 5
+
+// Message is the part of a received email used to correlate it with a
+// device and decide whether the scan arrived.
+type Message struct {
+	From        string // Header From address
+	Subject     string // Decoded subject
+	MessageID   string
+	Attachments []Attachment
+}
+
+// Attachment is a file carried by the message.
+type Attachment struct {
+	Filename    string `json:"filename"`
+	ContentType string `json:"content_type"`
+	Size        int64  `json:"size"` // Decoded bytes
+}
+
+// ParseMessage reads the headers and attachments of a raw RFC 5322 message.
+// Bodies are only measured, never kept.
+func ParseMessage(data []byte) (*Message, error) {
+	msg, err := mail.ReadMessage(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("parse message: %w", err)
+	}
+	dec := new(mime.WordDecoder)
+	out := &Message{MessageID: strings.Trim(msg.Header.Get("Message-Id"), "<> ")}
+	if subject, err := dec.DecodeHeader(msg.Header.Get("Subject")); err == nil {
+		out.Subject = strings.TrimSpace(subject)
+	} else {
+		out.Subject = strings.TrimSpace(msg.Header.Get("Subject"))
+	}
+	if addr, err := mail.ParseAddress(msg.Header.Get("From")); err == nil {
+		out.From = addr.Address
+	} else {
+		out.From = strings.TrimSpace(msg.Header.Get("From"))
+	}
+
+	ct := msg.Header.Get("Content-Type")
+	if err := collectParts(out, ct, msg.Header.Get("Content-Transfer-Encoding"), msg.Header.Get("Content-Disposition"), msg.Body, 0); err != nil {
+		return out, err
+	}
+	return out, nil
+}
+
+// collectParts walks a MIME entity, recording parts that are files.
+func collectParts(out *Message, contentType, encoding, disposition string, body io.Reader, depth int) error {
+	mediaType, params, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		mediaType, params = "text/plain", nil
+	}
+	if strings.HasPrefix(mediaType, "multipart/") && params["boundary"] != "" {
+		if depth >= maxPartDepth {
+			return nil
+		}
+		mr := multipart.NewReader(body, params["boundary"])
+		for {
+			part, err := mr.NextRawPart()
+			if err == io.EOF {
+				return nil
+			}
+			if err != nil {
+				return fmt.Errorf("read multipart: %w", err)
+			}
+			err = collectParts(out, part.Header.Get("Content-Type"), part.Header.Get("Content-Transfer-Encoding"), part.Header.Get("Content-Disposition"), part, depth+1)
+			part.Close()
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	filename := ""
+	if _, dparams, err := mime.ParseMediaType(disposition); err == nil {
+		filename = dparams["filename"]
+	}
+	if filename == "" {
+		filename = params["name"]
+	}
+	isFile := filename != "" || !(strings.HasPrefix(mediaType, "text/") || mediaType == "message/rfc822")
+	if !isFile {
+		_, err := io.Copy(io.Discard, body)
+		return err
+	}
+	if dec, err := new(mime.WordDecoder).DecodeHeader(filename); err == nil {
+		filename = dec
+	}
+
+	var r io.Reader = body
+	switch strings.ToLower(strings.TrimSpace(encoding)) {
+	case "base64":
+		// The decoder skips the line breaks of wrapped bodies
+		r = base64.NewDecoder(base64.StdEncoding, body)
+	case "quoted-printable":
+		r = quotedprintable.NewReader(body)
+	}
+	n, err := io.Copy(io.Discard, r)
+	if err != nil {
+		return fmt.Errorf("read attachment %q: %w", filename, err)
+	}
+	out.Attachments = append(out.Attachments, Attachment{Filename: filename, ContentType: mediaType, Size: n})
+	return nil
+}
@@ -0,0 +1,117 @@
+package scanmail
+
+import (
+	"context"
+	"net"
+	"net/smtp"
+	"strings"
+	"sync"
+	"testing"
+)
+
+const testScan = "From: \"MFP Lobby\" <scanner@example.com>\r\n" +
+	"To: scans@printmaster.local\r\n" +
+	"Subject: =?UTF-8?Q?Scan_from_CNB12345_=E2=80=93_test?=\r\n" +
+	"Message-ID: <abc@mfp>\r\n" +
+	"MIME-Version: 1.0\r\n" +
+	"Content-Type: multipart/mixed; boundary=\"b1\"\r\n" +
+	"\r\n" +
+	"--b1\r\n" +
+	"Content-Type: multipart/alternative; boundary=\"b2\"\r\n" +
+	"\r\n" +
+	"--b2\r\n" +
+	"Content-Type: text/plain\r\n" +
+	"\r\n" +
+	"Scanned document attached.\r\n" +
+	"--b2--\r\n" +
+	"--b1\r\n" +
+	"Content-Type: application/pdf; name=\"scan_0001.pdf\"\r\n" +
+	"Content-Transfer-Encoding: base64\r\n" +
+	"Content-Disposition: attachment; filename=\"scan_0001.pdf\"\r\n" +
+	"\r\n" +
+	"JVBERi0xLjQKJcfs\r\nj6IKMSAwIG9iago=\r\n" +
+	"--b1--\r\n"
+
+func TestParseMessage(t *testing.T) {
+	t.Parallel()
+	msg, err := ParseMessage([]byte(testScan))
+	if err != nil {
+		t.Fatalf("ParseMessage: %v", err)
+	}
+	if msg.From != "scanner@example.com" || msg.Subject != "Scan from CNB12345 – test" || msg.MessageID != "abc@mfp" {
+		t.Errorf("headers = %+v", msg)
+	}
+	if len(msg.Attachments) != 1 {
+		t.Fatalf("attachments = %+v", msg.Attachments)
+	}
+	if a := msg.Attachments[0]; a.Filename != "scan_0001.pdf" || a.ContentType != "application/pdf" || a.Size != 23 {
+		t.Errorf("attachment = %+v", a)
+	}
+
+	plain, err := ParseMessage([]byte("From: a@b\r\nSubject: hi\r\n\r\nno attachment\r\n"))
+	if err != nil || len(plain.Attachments) != 0 {
+		t.Errorf("plain message: %+v, %v", plain, err)
+	}
+}
+
+func TestServerReceivesMail(t *testing.T) {
+	t.Parallel()
+	var mu sync.Mutex
+	var got []*Envelope
+	srv := &Server{Addr: "127.0.0.1:0", Hostname: "pm-test", MaxMessageBytes: 4096, Handler: func(ctx context.Context, env *Envelope) error {
+		mu.Lock()
+		defer mu.Unlock()
+		got = append(got, env)
+		return nil
+	}}
+	if err := srv.ListenAndServe(); err != nil {
+		t.Fatalf("ListenAndServe: %v", err)
+	}
+	defer srv.Close()
+	addr := srv.ListenAddr().String()
+
+	if err := smtp.SendMail(addr, nil, "scanner@example.com", []string{"scans@printmaster.local"}, []byte(testScan)); err != nil {
+		t.Fatalf("SendMail: %v", err)
+	}
+	mu.Lock()
+	if len(got) != 1 || got[0].MailFrom != "scanner@example.com" || got[0].RemoteIP != "127.0.0.1" || got[0].Helo == "" {
+		t.Fatalf("envelopes = %+v", got)
+	}
+	if !strings.Contains(string(got[0].Data), "scan_0001.pdf") {
+		t.Error("message data not delivered")
+	}
+	mu.Unlock()
+
+	// Oversized messages are refused, the connection stays usable
+	big := "Subject: big\r\n\r\n" + strings.Repeat("x", 5000) + "\r\n"
+	if err := smtp.SendMail(addr, nil, "a@b", []string{"c@d"}, []byte(big)); err == nil || !strings.Contains(err.Error(), "552") {
+		t.Errorf("oversized message: %v", err)
+	}
+}
+
+func TestServerRejectsDisallowedNetworks(t *testing.T) {
+	t.Parallel()
+	nets, err := ParseNetworks([]string{"10.0.0.0/8", "192.168.1.5"})
+	if err != nil || len(nets) != 2 {
+		t.Fatalf("ParseNetworks: %v, %v", nets, err)
+	}
+	if _, err := ParseNetworks([]string{"not-an-ip"}); err == nil {
+		t.Error("expected invalid network error")
+	}
+
+	srv := &Server{Addr: "127.0.0.1:0", AllowedNetworks: nets, Handler: func(context.Context, *Envelope) error { return nil }}
+	if err := srv.ListenAndServe(); err != nil {
+		t.Fatalf("ListenAndServe: %v", err)
+	}
+	defer srv.Close()
+	conn, err := net.Dial("tcp", srv.ListenAddr().String())
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	defer conn.Close()
+	buf := make([]byte, 128)
+	n, _ := conn.Read(buf)
+	if !strings.HasPrefix(string(buf[:n]), "554") {
+		t.Errorf("greeting = %q, want 554 rejection", buf[:n])
+	}
+}
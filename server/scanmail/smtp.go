@@ -0,0 +1,431 @@
+// Package scanmail is a minimal SMTP sink for scan-to-email test messages.
+// MFPs are pointed at it while being onboarded; every message is accepted,
+// handed to a Handler and discarded. It implements just enough of RFC 5321
+// for device mail clients: no relaying, authentication or TLS.
+package scanmail
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultMaxMessageBytes bounds a message; scans are mostly PDFs.
+	DefaultMaxMessageBytes = 25 << 20
+	maxRecipients          = 100
+	maxLineLength          = 4096
+	commandTimeout         = 2 * time.Minute
+	dataTimeout            = 10 * time.Minute
+)
+
+// Envelope is one message received by the sink.
+type Envelope struct {
+	RemoteIP   string
+	Helo       string
+	MailFrom   string
+	RcptTo     []string
+	Data       []byte
+	ReceivedAt time.Time
+}
+
+// Handler processes a received message. An error makes the sink answer with
+// a temporary failure so the device can report it.
+type Handler func(ctx context.Context, env *Envelope) error
+
+// Server accepts SMTP connections and passes each message to Handler.
+type Server struct {
+	Addr            string
+	Hostname        string // Greeting name; defaults to "printmaster"
+	MaxMessageBytes int64
+	// AllowedNetworks limits which clients may connect; empty allows all
+	AllowedNetworks []*net.IPNet
+	Handler         Handler
+	Logger          *slog.Logger
+
+	mu     sync.Mutex
+	ln     net.Listener
+	conns  map[net.Conn]struct{}
+	closed bool
+	wg     sync.WaitGroup
+}
+
+// ParseNetworks parses CIDRs or single IPs for AllowedNetworks.
+func ParseNetworks(values []string) ([]*net.IPNet, error) {
+	var nets []*net.IPNet
+	for _, v := range values {
+		v = strings.TrimSpace(v)
+		if v == "" {
+			continue
+		}
+		if !strings.Contains(v, "/") {
+			ip := net.ParseIP(v)
+			if ip == nil {
+				return nil, fmt.Errorf("invalid address %q", v)
+			}
+			bits := 128
+			if ip.To4() != nil {
+				ip, bits = ip.To4(), 32
+			}
+			nets = append(nets, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		_, n, err := net.ParseCIDR(v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid network %q: %w", v, err)
+		}
+		nets = append(nets, n)
+	}
+	return nets, nil
+}
+
+// ListenAndServe binds Addr and serves connections in the background.
+func (s *Server) ListenAndServe() error {
+	if s.Handler == nil {
+		return errors.New("scanmail: no handler")
+	}
+	ln, err := net.Listen("tcp", s.Addr)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	s.ln = ln
+	s.conns = make(map[net.Conn]struct{})
+	s.mu.Unlock()
+	s.wg.Add(1)
+	go s.acceptLoop(ln)
+	return nil
+}
+
+// ListenAddr returns the bound address, or nil before ListenAndServe.
+func (s *Server) ListenAddr() net.Addr {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.ln == nil {
+		return nil
+	}
+	return s.ln.Addr()
+}
+
+// Close stops accepting, closes open connections and waits for handlers.
+func (s *Server) Close() error {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return nil
+	}
+	s.closed = true
+	var err error
+	if s.ln != nil {
+		err = s.ln.Close()
+	}
+	for c := range s.conns {
+		c.Close()
+	}
+	s.mu.Unlock()
+	s.wg.Wait()
+	return err
+}
+
+func (s *Server) logger() *slog.Logger {
+	if s.Logger != nil {
+		return s.Logger
+	}
+	return slog.Default()
+}
+
+func (s *Server) acceptLoop(ln net.Listener) {
+	defer s.wg.Done()
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			s.mu.Lock()
+			closed := s.closed
+			s.mu.Unlock()
+			if closed {
+				return
+			}
+			var ne net.Error
+			if errors.As(err, &ne) && ne.Timeout() {
+				time.Sleep(100 * time.Millisecond)
+				continue
+			}
+			s.logger().Warn("Scan mail: accept failed", "error", err)
+			return
+		}
+		s.mu.Lock()
+		if s.closed {
+			s.mu.Unlock()
+			conn.Close()
+			return
+		}
+		s.conns[conn] = struct{}{}
+		s.wg.Add(1)
+		s.mu.Unlock()
+		go func() {
+			defer s.wg.Done()
+			s.serveConn(conn)
+			s.mu.Lock()
+			delete(s.conns, conn)
+			s.mu.Unlock()
+		}()
+	}
+}
+
+func (s *Server) allowed(ip net.IP) bool {
+	if len(s.AllowedNetworks) == 0 {
+		return true
+	}
+	for _, n := range s.AllowedNetworks {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// session is the state of one SMTP connection.
+type session struct {
+	s        *Server
+	conn     net.Conn
+	r        *bufio.Reader
+	w        *bufio.Writer
+	remoteIP string
+	helo     string
+	from     string
+	hasFrom  bool
+	rcpts    []string
+}
+
+func (s *Server) serveConn(conn net.Conn) {
+	defer conn.Close()
+	remoteIP := ""
+	if addr, ok := conn.RemoteAddr().(*net.TCPAddr); ok {
+		remoteIP = addr.IP.String()
+		if !s.allowed(addr.IP) {
+			fmt.Fprintf(conn, "554 5.7.1 %s not allowed\r\n", remoteIP)
+			s.logger().Warn("Scan mail: rejected connection", "remote_ip", remoteIP)
+			return
+		}
+	}
+	sess := &session{s: s, conn: conn, r: bufio.NewReader(conn), w: bufio.NewWriter(conn), remoteIP: remoteIP}
+	sess.reply(220, fmt.Sprintf("%s ESMTP PrintMaster scan-to-email sink", s.hostname()))
+	for {
+		conn.SetReadDeadline(time.Now().Add(commandTimeout))
+		line, err := sess.readLine()
+		if err != nil {
+			if errors.Is(err, errLineTooLong) {
+				sess.reply(500, "5.5.2 line too long")
+				continue
+			}
+			return
+		}
+		verb, arg, _ := strings.Cut(line, " ")
+		if !sess.handle(strings.ToUpper(verb), strings.TrimSpace(arg)) {
+			return
+		}
+	}
+}
+
+func (s *Server) hostname() string {
+	if h := strings.TrimSpace(s.Hostname); h != "" {
+		return h
+	}
+	return "printmaster"
+}
+
+func (s *Server) maxBytes() int64 {
+	if s.MaxMessageBytes > 0 {
+		return s.MaxMessageBytes
+	}
+	return DefaultMaxMessageBytes
+}
+
+var errLineTooLong = errors.New("line too long")
+
+// readLine reads one CRLF (or LF) terminated command line.
+func (sess *session) readLine() (string, error) {
+	var line []byte
+	for {
+		chunk, isPrefix, err := sess.r.ReadLine()
+		if err != nil {
+			return "", err
+		}
+		line = append(line, chunk...)
+		if len(line) > maxLineLength {
+			// Drain the rest of the line before reporting
+			for isPrefix {
+				if _, isPrefix, err = sess.r.ReadLine(); err != nil {
+					return "", err
+				}
+			}
+			return "", errLineTooLong
+		}
+		if !isPrefix {
+			return string(line), nil
+		}
+	}
+}
+
+func (sess *session) reply(code int, lines ...string) {
+	for i, l := range lines {
+		sep := " "
+		if i < len(lines)-1 {
+			sep = "-"
+		}
+		fmt.Fprintf(sess.w, "%d%s%s\r\n", code, sep, l)
+	}
+	sess.w.Flush()
+}
+
+func (sess *session) reset() {
+	sess.from, sess.hasFrom, sess.rcpts = "", false, nil
+}
+
+// handle runs one command; false ends the connection.
+func (sess *session) handle(verb, arg string) bool {
+	switch verb {
+	case "HELO":
+		sess.helo = arg
+		sess.reset()
+		sess.reply(250, sess.s.hostname())
+	case "EHLO":
+		sess.helo = arg
+		sess.reset()
+		sess.reply(250, sess.s.hostname(), "8BITMIME", "SIZE "+strconv.FormatInt(sess.s.maxBytes(), 10))
+	case "MAIL":
+		if sess.helo == "" {
+			sess.reply(503, "5.5.1 send HELO/EHLO first")
+			return true
+		}
+		addr, params, ok := parsePath(arg, "FROM:")
+		if !ok {
+			sess.reply(501, "5.5.4 syntax: MAIL FROM:<address>")
+			return true
+		}
+		if size := paramValue(params, "SIZE"); size != "" {
+			if n, err := strconv.ParseInt(size, 10, 64); err == nil && n > sess.s.maxBytes() {
+				sess.reply(552, "5.3.4 message too large")
+				return true
+			}
+		}
+		sess.reset()
+		sess.from, sess.hasFrom = addr, true
+		sess.reply(250, "2.1.0 OK")
+	case "RCPT":
+		if !sess.hasFrom {
+			sess.reply(503, "5.5.1 send MAIL first")
+			return true
+		}
+		addr, _, ok := parsePath(arg, "TO:")
+		if !ok || addr == "" {
+			sess.reply(501, "5.5.4 syntax: RCPT TO:<address>")
+			return true
+		}
+		if len(sess.rcpts) >= maxRecipients {
+			sess.reply(452, "4.5.3 too many recipients")
+			return true
+		}
+		sess.rcpts = append(sess.rcpts, addr)
+		sess.reply(250, "2.1.5 OK")
+	case "DATA":
+		if len(sess.rcpts) == 0 {
+			sess.reply(503, "5.5.1 send RCPT first")
+			return true
+		}
+		return sess.data()
+	case "RSET":
+		sess.reset()
+		sess.reply(250, "2.0.0 OK")
+	case "NOOP":
+		sess.reply(250, "2.0.0 OK")
+	case "VRFY":
+		sess.reply(252, "2.5.0 cannot verify")
+	case "QUIT":
+		sess.reply(221, "2.0.0 bye")
+		return false
+	case "STARTTLS", "AUTH":
+		sess.reply(502, "5.5.1 not supported; configure the device without TLS or authentication")
+	default:
+		sess.reply(500, "5.5.2 unrecognized command")
+	}
+	return true
+}
+
+// data reads the message body and hands it to the handler.
+func (sess *session) data() bool {
+	sess.reply(354, "end data with <CR><LF>.<CR><LF>")
+	sess.conn.SetReadDeadline(time.Now().Add(dataTimeout))
+	dr := textproto.NewReader(sess.r).DotReader()
+	limit := sess.s.maxBytes()
+	data, err := io.ReadAll(io.LimitReader(dr, limit+1))
+	if err != nil {
+		return false
+	}
+	if int64(len(data)) > limit {
+		if _, err := io.Copy(io.Discard, dr); err != nil {
+			return false
+		}
+		sess.reset()
+		sess.reply(552, "5.3.4 message too large")
+		return true
+	}
+
+	env := &Envelope{
+		RemoteIP:   sess.remoteIP,
+		Helo:       sess.helo,
+		MailFrom:   sess.from,
+		RcptTo:     sess.rcpts,
+		Data:       data,
+		ReceivedAt: time.Now().UTC(),
+	}
+	sess.reset()
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	if err := sess.s.Handler(ctx, env); err != nil {
+		sess.s.logger().Warn("Scan mail: handler failed", "remote_ip", env.RemoteIP, "error", err)
+		sess.reply(451, "4.3.0 message not processed")
+		return true
+	}
+	sess.reply(250, "2.0.0 OK: received")
+	return true
+}
+
+// parsePath parses "FROM:<addr> PARAMS" (prefix "FROM:" or "TO:").
+func parsePath(arg, prefix string) (string, []string, bool) {
+	if len(arg) < len(prefix) || !strings.EqualFold(arg[:len(prefix)], prefix) {
+		return "", nil, false
+	}
+	rest := strings.TrimSpace(arg[len(prefix):])
+	var addr string
+	if strings.HasPrefix(rest, "<") {
+		end := strings.IndexByte(rest, '>')
+		if end < 0 {
+			return "", nil, false
+		}
+		addr, rest = rest[1:end], rest[end+1:]
+	} else {
+		// Some device firmware omits the angle brackets
+		addr, rest, _ = strings.Cut(rest, " ")
+	}
+	return strings.TrimSpace(addr), strings.Fields(rest), true
+}
+
+func paramValue(params []string, key string) string {
+	for _, p := range params {
+		k, v, _ := strings.Cut(p, "=")
+		if strings.EqualFold(k, key) {
+			return v
+		}
+	}
+	return ""
+}
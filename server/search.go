@@ -0,0 +1,296 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+
+	authz "printmaster/server/authz"
+	"printmaster/server/storage"
+)
+
+const (
+	searchMinQueryLen   = 2
+	searchDefaultLimit  = 25
+	searchMaxLimit      = 100
+	searchTypeTenant    = "tenant"
+	searchTypeAgent     = "agent"
+	searchTypeDevice    = "device"
+	searchMatchExact    = "exact"
+	searchMatchPrefix   = "prefix"
+	searchMatchContains = "contains"
+)
+
+// SearchResult is one hit from the unified search.
+type SearchResult struct {
+	Type       string `json:"type"` // tenant, agent or device
+	ID         string `json:"id"`   // Tenant ID, agent ID or device serial
+	Title      string `json:"title"`
+	Subtitle   string `json:"subtitle,omitempty"`
+	TenantID   string `json:"tenant_id,omitempty"`
+	TenantName string `json:"tenant_name,omitempty"`
+	AgentID    string `json:"agent_id,omitempty"`
+	AgentName  string `json:"agent_name,omitempty"`
+	MatchField string `json:"match_field"`
+	MatchValue string `json:"match_value"`
+	Match      string `json:"match"` // exact, prefix or contains
+
+	rank int
+}
+
+// searchField is a named value a search term is compared against.
+type searchField struct {
+	name  string
+	value string
+}
+
+// handleSearch serves GET /api/v1/search?q=&types=tenant,agent,device&limit=.
+//
+// Tenants, agents and devices are matched case-insensitively on their
+// identifying fields. Results are limited to the caller's tenants; exact
+// matches rank above prefix matches, which rank above substring matches.
+func handleSearch(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "GET only", http.StatusMethodNotAllowed)
+		return
+	}
+	if !authorizeOrReject(w, r, authz.ActionDevicesRead, authz.ResourceRef{}) {
+		return
+	}
+	principal := getPrincipal(r)
+	scope, ok := tenantScope(principal)
+	if !ok {
+		http.Error(w, "forbidden", http.StatusForbidden)
+		return
+	}
+
+	q := r.URL.Query()
+	query := strings.TrimSpace(q.Get("q"))
+	if len([]rune(query)) < searchMinQueryLen {
+		http.Error(w, "q must be at least 2 characters", http.StatusBadRequest)
+		return
+	}
+	limit := searchDefaultLimit
+	if v := q.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			http.Error(w, "invalid limit", http.StatusBadRequest)
+			return
+		}
+		limit = min(n, searchMaxLimit)
+	}
+	types := map[string]bool{searchTypeTenant: true, searchTypeAgent: true, searchTypeDevice: true}
+	if v := strings.TrimSpace(q.Get("types")); v != "" {
+		types = map[string]bool{}
+		for _, t := range strings.Split(v, ",") {
+			t = strings.TrimSuffix(strings.ToLower(strings.TrimSpace(t)), "s")
+			switch t {
+			case searchTypeTenant, searchTypeAgent, searchTypeDevice:
+				types[t] = true
+			case "":
+			default:
+				http.Error(w, "unknown type "+t, http.StatusBadRequest)
+				return
+			}
+		}
+	}
+
+	ctx := r.Context()
+	tenants, err := serverStore.ListTenants(ctx)
+	if err != nil {
+		logError("Search: failed to list tenants", "error", err)
+		http.Error(w, "search failed", http.StatusInternalServerError)
+		return
+	}
+	tenantNames := make(map[string]string, len(tenants))
+	for _, t := range tenants {
+		if t != nil {
+			tenantNames[t.ID] = t.Name
+		}
+	}
+	agents, err := serverStore.ListAgents(ctx)
+	if err != nil {
+		logError("Search: failed to list agents", "error", err)
+		http.Error(w, "search failed", http.StatusInternalServerError)
+		return
+	}
+
+	needle := strings.ToLower(query)
+	var results []SearchResult
+
+	if types[searchTypeTenant] {
+		for _, t := range tenants {
+			if t == nil || !tenantAllowed(scope, t.ID) {
+				continue
+			}
+			res, ok := matchSearchFields(needle, []searchField{
+				{"name", t.Name},
+				{"id", t.ID},
+				{"billing_code", t.BillingCode},
+				{"login_domain", t.LoginDomain},
+				{"contact_email", t.ContactEmail},
+			})
+			if !ok {
+				continue
+			}
+			res.Type = searchTypeTenant
+			res.ID = t.ID
+			res.Title = t.Name
+			res.Subtitle = t.ContactName
+			res.TenantID = t.ID
+			res.TenantName = t.Name
+			results = append(results, res)
+		}
+	}
+
+	// Devices inherit their agent's tenant
+	agentsByID := make(map[string]*storage.Agent, len(agents))
+	for _, a := range agents {
+		if a == nil || !tenantAllowed(scope, a.TenantID) {
+			continue
+		}
+		agentsByID[a.AgentID] = a
+		if !types[searchTypeAgent] {
+			continue
+		}
+		res, ok := matchSearchFields(needle, []searchField{
+			{"name", a.Name},
+			{"hostname", a.Hostname},
+			{"ip", a.IP},
+			{"agent_id", a.AgentID},
+		})
+		if !ok {
+			continue
+		}
+		res.Type = searchTypeAgent
+		res.ID = a.AgentID
+		res.Title = agentDisplayName(a)
+		res.Subtitle = strings.TrimSpace(a.Hostname + " " + a.IP)
+		res.TenantID = a.TenantID
+		res.TenantName = tenantNames[a.TenantID]
+		res.AgentID = a.AgentID
+		res.AgentName = agentDisplayName(a)
+		results = append(results, res)
+	}
+
+	if types[searchTypeDevice] {
+		devices, err := serverStore.ListAllDevices(ctx)
+		if err != nil {
+			logError("Search: failed to list devices", "error", err)
+			http.Error(w, "search failed", http.StatusInternalServerError)
+			return
+		}
+		for _, d := range devices {
+			if d == nil {
+				continue
+			}
+			a, ok := agentsByID[d.AgentID]
+			if !ok {
+				continue
+			}
+			res, ok := matchSearchFields(needle, []searchField{
+				{"serial", d.Serial},
+				{"ip", d.IP},
+				{"asset_number", d.AssetNumber},
+				{"hostname", d.Hostname},
+				{"mac_address", d.MACAddress},
+			})
+			if !ok {
+				continue
+			}
+			res.Type = searchTypeDevice
+			res.ID = d.Serial
+			res.Title = strings.TrimSpace(d.Manufacturer + " " + d.Model)
+			if res.Title == "" {
+				res.Title = d.Serial
+			}
+			res.Subtitle = strings.TrimSpace(d.Serial + " " + d.IP)
+			res.TenantID = a.TenantID
+			res.TenantName = tenantNames[a.TenantID]
+			res.AgentID = a.AgentID
+			res.AgentName = agentDisplayName(a)
+			results = append(results, res)
+		}
+	}
+
+	sort.SliceStable(results, func(i, j int) bool {
+		if results[i].rank != results[j].rank {
+			return results[i].rank < results[j].rank
+		}
+		return strings.ToLower(results[i].Title) < strings.ToLower(results[j].Title)
+	})
+	total := len(results)
+	if total > limit {
+		results = results[:limit]
+	}
+	if results == nil {
+		results = []SearchResult{}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"query":     query,
+		"results":   results,
+		"total":     total,
+		"truncated": total > limit,
+	})
+}
+
+// matchSearchFields returns the best match of needle (lower case) among the
+// fields. MAC-like needles also match MAC addresses with separators ignored.
+func matchSearchFields(needle string, fields []searchField) (SearchResult, bool) {
+	best := SearchResult{rank: -1}
+	bareMAC := ""
+	if strings.Trim(needle, "0123456789abcdef:-") == "" {
+		bareMAC = compactMAC(needle)
+	}
+	for _, f := range fields {
+		if f.value == "" {
+			continue
+		}
+		match, rank := searchMatch(needle, strings.ToLower(f.value))
+		if match == "" && f.name == "mac_address" && len(bareMAC) >= 4 {
+			match, rank = searchMatch(bareMAC, compactMAC(f.value))
+		}
+		if match == "" || (best.rank >= 0 && rank >= best.rank) {
+			continue
+		}
+		best = SearchResult{MatchField: f.name, MatchValue: f.value, Match: match, rank: rank}
+	}
+	return best, best.rank >= 0
+}
+
+func searchMatch(needle, value string) (string, int) {
+	switch {
+	case value == needle:
+		return searchMatchExact, 0
+	case strings.HasPrefix(value, needle):
+		return searchMatchPrefix, 1
+	case strings.Contains(value, needle):
+		return searchMatchContains, 2
+	}
+	return "", 0
+}
+
+// compactMAC lower-cases s and drops everything but hex digits.
+func compactMAC(s string) string {
+	return strings.Map(func(r rune) rune {
+		r = unicode.ToLower(r)
+		if (r >= '0' && r <= '9') || (r >= 'a' && r <= 'f') {
+			return r
+		}
+		return -1
+	}, s)
+}
+
+func agentDisplayName(a *storage.Agent) string {
+	if a.Name != "" {
+		return a.Name
+	}
+	if a.Hostname != "" {
+		return a.Hostname
+	}
+	return a.AgentID
+}
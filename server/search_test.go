@@ -0,0 +1,103 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"printmaster/server/storage"
+)
+
+func TestHandleSearch(t *testing.T) {
+	store := SetupTestStore(t)
+	ctx := context.Background()
+	now := time.Now()
+
+	for _, tn := range []*storage.Tenant{{ID: "tenant-a", Name: "Acme"}, {ID: "tenant-b", Name: "Globex"}} {
+		if err := store.CreateTenant(ctx, tn); err != nil {
+			t.Fatalf("CreateTenant: %v", err)
+		}
+	}
+	for _, a := range []*storage.Agent{
+		{AgentID: "agent-a", Name: "Acme HQ", Hostname: "acme-pc", Token: "ta", TenantID: "tenant-a", Status: "active", RegisteredAt: now, LastSeen: now},
+		{AgentID: "agent-b", Hostname: "globex-pc", Token: "tb", TenantID: "tenant-b", Status: "active", RegisteredAt: now, LastSeen: now},
+	} {
+		if err := store.RegisterAgent(ctx, a); err != nil {
+			t.Fatalf("RegisterAgent: %v", err)
+		}
+	}
+	for _, d := range []struct{ serial, agentID, ip, mac, asset string }{
+		{"CNB12345", "agent-a", "10.0.0.5", "00:11:22:AA:BB:CC", "AST-0042"},
+		{"CNB123", "agent-b", "10.1.0.5", "00:11:22:DD:EE:FF", ""},
+	} {
+		dev := &storage.Device{}
+		dev.Serial, dev.AgentID, dev.IP, dev.MACAddress, dev.AssetNumber = d.serial, d.agentID, d.ip, d.mac, d.asset
+		dev.LastSeen = now
+		if err := store.UpsertDevice(ctx, dev); err != nil {
+			t.Fatalf("UpsertDevice: %v", err)
+		}
+	}
+
+	search := func(user *storage.User, query string) ([]SearchResult, int) {
+		t.Helper()
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/search?"+query, nil)
+		rr := httptest.NewRecorder()
+		handleSearch(rr, InjectTestUser(req, user))
+		if rr.Code != http.StatusOK {
+			return nil, rr.Code
+		}
+		var body struct {
+			Results []SearchResult `json:"results"`
+		}
+		if err := json.Unmarshal(rr.Body.Bytes(), &body); err != nil {
+			t.Fatalf("decode: %v", err)
+		}
+		return body.Results, rr.Code
+	}
+
+	admin := NewTestAdminUser()
+	results, _ := search(admin, "q=CNB123")
+	if len(results) != 2 || results[0].ID != "CNB123" || results[0].Match != searchMatchExact {
+		t.Fatalf("expected exact serial match first, got %+v", results)
+	}
+	if results[0].TenantName != "Globex" || results[1].Match != searchMatchPrefix {
+		t.Errorf("unexpected results: %+v", results)
+	}
+
+	// MAC addresses match without separators; asset numbers are searchable
+	results, _ = search(admin, "q="+url.QueryEscape("0011-22aa"))
+	if len(results) != 1 || results[0].ID != "CNB12345" || results[0].MatchField != "mac_address" {
+		t.Errorf("MAC search = %+v", results)
+	}
+	results, _ = search(admin, "q=ast-0042")
+	if len(results) != 1 || results[0].MatchField != "asset_number" {
+		t.Errorf("asset search = %+v", results)
+	}
+
+	// Type filter
+	results, _ = search(admin, "q=acme&types=agents")
+	if len(results) != 1 || results[0].Type != searchTypeAgent || results[0].TenantName != "Acme" {
+		t.Errorf("agent search = %+v", results)
+	}
+
+	// Tenant-scoped users only see their tenants
+	scoped := NewTestUser(storage.RoleViewer, "tenant-a")
+	results, _ = search(scoped, "q=CNB")
+	if len(results) != 1 || results[0].TenantID != "tenant-a" {
+		t.Errorf("scoped device search = %+v", results)
+	}
+	if results, _ = search(scoped, "q=globex"); len(results) != 0 {
+		t.Errorf("scoped user saw other tenant: %+v", results)
+	}
+
+	if _, code := search(admin, "q=a"); code != http.StatusBadRequest {
+		t.Errorf("short query status = %d", code)
+	}
+	if _, code := search(admin, "q=acme&types=printers"); code != http.StatusBadRequest {
+		t.Errorf("unknown type status = %d", code)
+	}
+}
@@ -0,0 +1,144 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"printmaster/common/logger"
+	"printmaster/server/storage"
+)
+
+// setupSessionTest installs a test store and config and creates two users.
+func setupSessionTest(t *testing.T, idleMinutes int) (storage.Store, *storage.User, *storage.User) {
+	t.Helper()
+	prevConfig, prevLogger := serverConfig, serverLogger
+	t.Cleanup(func() { serverConfig, serverLogger = prevConfig, prevLogger })
+	serverLogger = logger.New(logger.ERROR, "", 10)
+	serverConfig = DefaultConfig()
+	serverConfig.Security.SessionIdleMinutes = idleMinutes
+
+	store := SetupTestStore(t)
+	ctx := context.Background()
+	alice := &storage.User{Username: "alice", Role: storage.RoleViewer}
+	bob := &storage.User{Username: "bob", Role: storage.RoleViewer}
+	for _, u := range []*storage.User{alice, bob} {
+		if err := store.CreateUser(ctx, u, "Password-123"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return store, alice, bob
+}
+
+func TestSessionIdleTimeout(t *testing.T) {
+	store, alice, _ := setupSessionTest(t, 30)
+	ctx := context.Background()
+
+	ses, err := store.CreateSession(ctx, alice.ID, 60)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hash := storage.TokenHash(ses.Token)
+
+	// Activity older than the touch interval is recorded
+	if err := store.TouchSession(ctx, hash, time.Now().Add(-10*time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := loadUserForSessionToken(ses.Token); err != nil {
+		t.Fatalf("active session rejected: %v", err)
+	}
+	got, err := store.GetSessionByToken(ctx, ses.Token)
+	if err != nil || time.Since(got.LastSeenAt) > time.Minute {
+		t.Fatalf("last activity not refreshed: %+v (%v)", got, err)
+	}
+
+	// Past the idle timeout the session is rejected and removed
+	if err := store.TouchSession(ctx, hash, time.Now().Add(-31*time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := loadUserForSessionToken(ses.Token); err != errSessionInvalid {
+		t.Fatalf("idle session: err = %v, want errSessionInvalid", err)
+	}
+	if _, err := store.GetSessionByToken(ctx, ses.Token); err == nil {
+		t.Fatal("idle session should be deleted")
+	}
+}
+
+func TestHandleMySessionsOnlyTouchesOwnSessions(t *testing.T) {
+	store, alice, bob := setupSessionTest(t, 0)
+	ctx := context.Background()
+
+	current, _ := store.CreateSessionForClient(ctx, alice.ID, 60, "10.0.0.1", "Mozilla/5.0 (Windows NT 10.0) Chrome/120.0")
+	other, _ := store.CreateSession(ctx, alice.ID, 60)
+	bobs, _ := store.CreateSession(ctx, bob.ID, 60)
+
+	request := func(method, path string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, nil)
+		req.Header.Set("Authorization", "Bearer "+current.Token)
+		rec := httptest.NewRecorder()
+		handleMySessions(rec, InjectTestUser(req, alice))
+		return rec
+	}
+
+	rec := request(http.MethodGet, "/api/v1/auth/sessions")
+	var listed []storage.Session
+	if err := json.Unmarshal(rec.Body.Bytes(), &listed); err != nil || len(listed) != 2 {
+		t.Fatalf("expected alice's 2 sessions, got %s", rec.Body.String())
+	}
+	for _, s := range listed {
+		if s.Token == storage.TokenHash(current.Token) && (s.IPAddress != "10.0.0.1" || !strings.Contains(s.UserAgent, "Chrome")) {
+			t.Fatalf("client details missing: %+v", s)
+		}
+	}
+
+	if rec := request(http.MethodDelete, "/api/v1/auth/sessions/"+storage.TokenHash(bobs.Token)); rec.Code != http.StatusNotFound {
+		t.Fatalf("revoking another user's session: status %d", rec.Code)
+	}
+	if rec := request(http.MethodDelete, "/api/v1/auth/sessions/others"); rec.Code != http.StatusOK {
+		t.Fatalf("revoke others: status %d (%s)", rec.Code, rec.Body.String())
+	}
+	if _, err := store.GetSessionByToken(ctx, other.Token); err == nil {
+		t.Fatal("other session should be revoked")
+	}
+	for _, token := range []string{current.Token, bobs.Token} {
+		if _, err := store.GetSessionByToken(ctx, token); err != nil {
+			t.Fatalf("session should survive: %v", err)
+		}
+	}
+}
+
+func TestHandleRevokeSessionsForceLogout(t *testing.T) {
+	store, alice, bob := setupSessionTest(t, 0)
+	ctx := context.Background()
+	a1, _ := store.CreateSession(ctx, alice.ID, 60)
+	a2, _ := store.CreateSession(ctx, alice.ID, 60)
+	b1, _ := store.CreateSession(ctx, bob.ID, 60)
+
+	body := `{"user_id": ` + strconv.FormatInt(alice.ID, 10) + `}`
+	req := InjectTestAdmin(httptest.NewRequest(http.MethodPost, "/api/v1/sessions/revoke", strings.NewReader(body)))
+	rec := httptest.NewRecorder()
+	handleRevokeSessions(rec, req)
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `"revoked":2`) {
+		t.Fatalf("force logout: status %d body %s", rec.Code, rec.Body.String())
+	}
+	for _, token := range []string{a1.Token, a2.Token} {
+		if _, err := store.GetSessionByToken(ctx, token); err == nil {
+			t.Fatal("alice should be logged out everywhere")
+		}
+	}
+	if _, err := store.GetSessionByToken(ctx, b1.Token); err != nil {
+		t.Fatal("bob's session should survive")
+	}
+
+	req = InjectTestUser(httptest.NewRequest(http.MethodPost, "/api/v1/sessions/revoke", strings.NewReader(`{"keys":["x"]}`)), bob)
+	rec = httptest.NewRecorder()
+	handleRevokeSessions(rec, req)
+	if rec.Code != http.StatusForbidden {
+		t.Fatalf("non-admin bulk revoke: status %d, want 403", rec.Code)
+	}
+}
@@ -2,11 +2,15 @@ package settings
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
+	"sort"
 	"strings"
 	"time"
 
 	pmsettings "printmaster/common/settings"
+	"printmaster/common/vendorrules"
 )
 
 // AgentSnapshot captures the subset of settings sent to agents plus a change token.
@@ -16,6 +20,8 @@ type AgentSnapshot struct {
 	UpdatedAt       time.Time           `json:"updated_at"`
 	Settings        pmsettings.Settings `json:"settings"`
 	ManagedSections []string            `json:"managed_sections,omitempty"` // e.g. ["discovery", "snmp", "features"]
+	FeatureFlags    map[string]bool     `json:"feature_flags,omitempty"`
+	DetectionRules  []vendorrules.Rule  `json:"detection_rules,omitempty"`
 }
 
 // BuildAgentSnapshot resolves the appropriate settings for an agent and rewrites the
@@ -53,7 +59,84 @@ func BuildAgentSnapshot(ctx context.Context, resolver *Resolver, tenantID string
 	if err != nil {
 		return AgentSnapshot{}, err
 	}
-	return agentSnapshotFromSnapshot(snapshot)
+	agentSnap, err := agentSnapshotFromSnapshot(snapshot)
+	if err != nil {
+		return AgentSnapshot{}, err
+	}
+
+	var flags map[string]bool
+	if agentID != "" {
+		flags, err = resolver.ResolveAgentFeatureFlags(ctx, agentID)
+	}
+	if flags == nil {
+		var flagSnap FeatureFlagsSnapshot
+		flagSnap, err = resolver.ResolveFeatureFlags(ctx, tenantID)
+		flags = flagSnap.Values()
+	}
+	if err != nil {
+		return AgentSnapshot{}, err
+	}
+	agentSnap, err = applyFeatureFlags(agentSnap, flags)
+	if err != nil {
+		return AgentSnapshot{}, err
+	}
+
+	stored, err := resolver.store.ListDetectionRules(ctx)
+	if err != nil {
+		return AgentSnapshot{}, err
+	}
+	rules := make([]vendorrules.Rule, 0, len(stored))
+	for _, r := range stored {
+		if r != nil && r.Enabled {
+			rules = append(rules, r.Rule)
+		}
+	}
+	return applyDetectionRules(agentSnap, rules), nil
+}
+
+// applyDetectionRules attaches the enabled detection rules and folds them
+// into the version, so agents resync when an admin edits a rule.
+func applyDetectionRules(snap AgentSnapshot, rules []vendorrules.Rule) AgentSnapshot {
+	rulesVersion := vendorrules.Version(rules)
+	if rulesVersion == "" {
+		return snap
+	}
+	snap.DetectionRules = rules
+	h := sha256.Sum256([]byte(snap.Version + "|rules=" + rulesVersion))
+	snap.Version = hex.EncodeToString(h[:])
+	return snap
+}
+
+// applyFeatureFlags attaches the resolved flags to the snapshot and turns off
+// the settings they gate. Flags left at their defaults do not change the
+// version, so agents only resync when an admin actually changes one.
+func applyFeatureFlags(snap AgentSnapshot, flags map[string]bool) (AgentSnapshot, error) {
+	snap.FeatureFlags = flags
+	if !pmsettings.FeatureEnabled(flags, pmsettings.FeatureJobAccounting) {
+		snap.Settings.Spooler.Enabled = false
+	}
+
+	keys := make([]string, 0, len(flags))
+	for key, enabled := range flags {
+		if enabled != pmsettings.FeatureEnabled(nil, key) {
+			keys = append(keys, key)
+		}
+	}
+	if len(keys) == 0 {
+		return snap, nil
+	}
+	sort.Strings(keys)
+	version, err := pmsettings.ComputeSettingsVersion(snap.SchemaVersion, snap.UpdatedAt, snap.Settings)
+	if err != nil {
+		return AgentSnapshot{}, err
+	}
+	material := version
+	for _, key := range keys {
+		material += fmt.Sprintf("|%s=%t", key, flags[key])
+	}
+	h := sha256.Sum256([]byte(material))
+	snap.Version = hex.EncodeToString(h[:])
+	return snap, nil
 }
 
 func agentSnapshotFromSnapshot(snapshot Snapshot) (AgentSnapshot, error) {
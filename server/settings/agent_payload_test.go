@@ -6,6 +6,7 @@ import (
 	"time"
 
 	pmsettings "printmaster/common/settings"
+	"printmaster/common/vendorrules"
 	"printmaster/server/storage"
 )
 
@@ -93,3 +94,39 @@ func TestBuildAgentSnapshotResolvesTenantOverrides(t *testing.T) {
 		t.Fatalf("expected settings version to be set")
 	}
 }
+
+func TestBuildAgentSnapshotIncludesDetectionRules(t *testing.T) {
+	store := newFakeStore()
+	resolver, err := NewResolver(store)
+	if err != nil {
+		t.Fatalf("NewResolver failed: %v", err)
+	}
+	base, err := BuildAgentSnapshot(context.Background(), resolver, "", "")
+	if err != nil {
+		t.Fatalf("build snapshot failed: %v", err)
+	}
+	if len(base.DetectionRules) != 0 {
+		t.Fatalf("expected no rules, got %+v", base.DetectionRules)
+	}
+
+	enabled := &storage.DetectionRule{Rule: vendorrules.Rule{ID: 1, Manufacturer: "Acme", SysObjectIDPrefix: "1.3.6.1.4.1.55555", Enabled: true}}
+	disabled := &storage.DetectionRule{Rule: vendorrules.Rule{ID: 2, Manufacturer: "Off", SysDescrRegex: "off"}}
+	store.rules = []*storage.DetectionRule{enabled, disabled}
+	withRules, err := BuildAgentSnapshot(context.Background(), resolver, "", "")
+	if err != nil {
+		t.Fatalf("build snapshot failed: %v", err)
+	}
+	if len(withRules.DetectionRules) != 1 || withRules.DetectionRules[0].Manufacturer != "Acme" {
+		t.Fatalf("expected only the enabled rule, got %+v", withRules.DetectionRules)
+	}
+	if withRules.Version == base.Version {
+		t.Fatalf("expected rules to change the snapshot version")
+	}
+
+	// Editing a rule changes the version again
+	enabled.Manufacturer = "Acme Corp"
+	edited, _ := BuildAgentSnapshot(context.Background(), resolver, "", "")
+	if edited.Version == withRules.Version {
+		t.Fatalf("expected rule edit to change the snapshot version")
+	}
+}
@@ -71,6 +71,7 @@ func NewAPI(store Store, resolver *Resolver, opts APIOptions) (*API, error) {
 func (api *API) RegisterRoutes(cfg RouteConfig) {
 	if cfg.RegisterTenantAlias {
 		tenancy.RegisterTenantSubresource("settings", nil)
+		tenancy.RegisterTenantSubresource("features", nil)
 	}
 	if !cfg.FeatureEnabled {
 		return
@@ -86,6 +87,7 @@ func (api *API) RegisterRoutes(cfg RouteConfig) {
 	mux.HandleFunc("/api/v1/settings/global", wrap(api.handleGlobal))
 	mux.HandleFunc("/api/v1/settings/tenants/", wrap(api.handleTenantSettingsRoute))
 	mux.HandleFunc("/api/v1/settings/agents/", wrap(api.handleAgentSettingsRoute))
+	api.registerFeatureFlagRoutes(mux, cfg.RegisterTenantAlias)
 
 	if cfg.RegisterTenantAlias {
 		tenancy.RegisterTenantSubresource("settings", api.tenantSubresourceHandler())
@@ -215,6 +217,15 @@ func (api *API) handleGlobal(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 		pmsettings.Sanitize(&payload)
+		current, err := api.resolver.ResolveGlobal(r.Context())
+		if err != nil {
+			writeStoreError(w, err)
+			return
+		}
+		sweepChanged := snmpSweepChanged(current.Settings, payload)
+		if sweepChanged && !api.authorize(w, r, authz.ActionSettingsSNMPSweepWrite, authz.ResourceRef{}) {
+			return
+		}
 		actor := api.actorLabel(r)
 
 		// Validate and normalize managed sections
@@ -245,6 +256,9 @@ func (api *API) handleGlobal(w http.ResponseWriter, r *http.Request) {
 				"schema_version": pmsettings.SchemaVersion,
 			},
 		})
+		if sweepChanged {
+			api.auditSNMPSweep(r, "global", "", payload.SNMP)
+		}
 	default:
 		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
 	}
@@ -305,6 +319,15 @@ func (api *API) handleTenantSettings(w http.ResponseWriter, r *http.Request, ten
 		if !api.authorize(w, r, authz.ActionSettingsFleetWrite, resource) {
 			return
 		}
+		existing, err := api.store.GetTenantSettings(r.Context(), tenantID)
+		if err != nil {
+			writeStoreError(w, err)
+			return
+		}
+		if existing != nil && overridesTouchSNMPSweep(existing.Overrides) &&
+			!api.authorize(w, r, authz.ActionSettingsSNMPSweepWrite, resource) {
+			return
+		}
 		if err := api.store.DeleteTenantSettings(r.Context(), tenantID); err != nil {
 			writeStoreError(w, err)
 			return
@@ -361,6 +384,15 @@ func (api *API) handleAgentSettings(w http.ResponseWriter, r *http.Request, agen
 		if !api.authorize(w, r, authz.ActionSettingsFleetWrite, resource) {
 			return
 		}
+		existing, err := api.store.GetAgentSettings(r.Context(), agentID)
+		if err != nil {
+			writeStoreError(w, err)
+			return
+		}
+		if existing != nil && overridesTouchSNMPSweep(existing.Overrides) &&
+			!api.authorize(w, r, authz.ActionSettingsSNMPSweepWrite, resource) {
+			return
+		}
 		if err := api.store.DeleteAgentSettings(r.Context(), agentID); err != nil {
 			writeStoreError(w, err)
 			return
@@ -475,6 +507,15 @@ func (api *API) saveTenantOverrides(w http.ResponseWriter, r *http.Request, tena
 		})
 		return
 	}
+	previous, err := ApplyPatch(globalSnap.Settings, existing)
+	if err != nil {
+		writeStoreError(w, err)
+		return
+	}
+	sweepChanged := snmpSweepChanged(previous, effective)
+	if sweepChanged && !api.authorize(w, r, authz.ActionSettingsSNMPSweepWrite, authz.ResourceRef{TenantIDs: []string{tenantID}}) {
+		return
+	}
 	actor := api.actorLabel(r)
 	keys := collectOverrideKeys(cleaned)
 	enforcedSections = normalizeSectionList(enforcedSections)
@@ -514,6 +555,9 @@ func (api *API) saveTenantOverrides(w http.ResponseWriter, r *http.Request, tena
 			},
 		})
 	}
+	if sweepChanged {
+		api.auditSNMPSweep(r, tenantID, tenantID, effective.SNMP)
+	}
 	snap, err := api.resolver.ResolveForTenant(r.Context(), tenantID)
 	if err != nil {
 		writeStoreError(w, err)
@@ -615,6 +659,21 @@ func (api *API) saveAgentOverrides(w http.ResponseWriter, r *http.Request, agent
 		})
 		return
 	}
+	previous, err := ApplyPatch(baseSnap.Settings, existing)
+	if err != nil {
+		writeStoreError(w, err)
+		return
+	}
+	sweepChanged := snmpSweepChanged(previous, effective)
+	if sweepChanged {
+		resource := authz.ResourceRef{}
+		if strings.TrimSpace(agent.TenantID) != "" {
+			resource = authz.ResourceRef{TenantIDs: []string{agent.TenantID}}
+		}
+		if !api.authorize(w, r, authz.ActionSettingsSNMPSweepWrite, resource) {
+			return
+		}
+	}
 
 	actor := api.actorLabel(r)
 	keys := collectOverrideKeys(cleaned)
@@ -653,6 +712,9 @@ func (api *API) saveAgentOverrides(w http.ResponseWriter, r *http.Request, agent
 			},
 		})
 	}
+	if sweepChanged {
+		api.auditSNMPSweep(r, agentID, agent.TenantID, effective.SNMP)
+	}
 
 	snap, err := api.resolver.ResolveForAgent(r.Context(), agentID)
 	if err != nil {
@@ -24,6 +24,8 @@ type fakeStore struct {
 	lastGlobal    *storage.SettingsRecord
 	lastTenant    *storage.TenantSettingsRecord
 	deleteCalls   []string
+	featureFlags  map[string]map[string]*storage.FeatureFlag
+	rules         []*storage.DetectionRule
 }
 
 func newFakeStore() *fakeStore {
@@ -32,6 +34,7 @@ func newFakeStore() *fakeStore {
 		tenantRecords: make(map[string]*storage.TenantSettingsRecord),
 		agents:        make(map[string]*storage.Agent),
 		agentRecords:  make(map[string]*storage.AgentSettingsRecord),
+		featureFlags:  make(map[string]map[string]*storage.FeatureFlag),
 	}
 }
 
@@ -112,6 +115,34 @@ func (s *fakeStore) DeleteAgentSettings(ctx context.Context, agentID string) err
 	return nil
 }
 
+func (s *fakeStore) ListFeatureFlags(ctx context.Context, tenantID string) ([]*storage.FeatureFlag, error) {
+	var flags []*storage.FeatureFlag
+	for _, flag := range s.featureFlags[tenantID] {
+		copy := *flag
+		flags = append(flags, &copy)
+	}
+	return flags, nil
+}
+
+func (s *fakeStore) ListDetectionRules(ctx context.Context) ([]*storage.DetectionRule, error) {
+	return s.rules, nil
+}
+
+func (s *fakeStore) SetFeatureFlag(ctx context.Context, flag *storage.FeatureFlag) error {
+	if s.featureFlags[flag.TenantID] == nil {
+		s.featureFlags[flag.TenantID] = make(map[string]*storage.FeatureFlag)
+	}
+	copy := *flag
+	copy.UpdatedAt = time.Now().UTC()
+	s.featureFlags[flag.TenantID][flag.Key] = &copy
+	return nil
+}
+
+func (s *fakeStore) DeleteFeatureFlag(ctx context.Context, tenantID, key string) error {
+	delete(s.featureFlags[tenantID], key)
+	return nil
+}
+
 func allowAllAuthorizer(_ *http.Request, _ authz.Action, _ authz.ResourceRef) error {
 	return nil
 }
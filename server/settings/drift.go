@@ -0,0 +1,100 @@
+package settings
+
+import (
+	"encoding/json"
+	"reflect"
+	"sort"
+
+	pmsettings "printmaster/common/settings"
+	"printmaster/server/storage"
+)
+
+// driftIgnoredPaths are fleet-section fields that legitimately differ per
+// agent: scan ranges are entered on the agent and the subnet is detected.
+var driftIgnoredPaths = map[string]bool{
+	"discovery.ranges_text":     true,
+	"discovery.detected_subnet": true,
+}
+
+// driftSecretPaths hold credentials; drift is reported without the values.
+var driftSecretPaths = map[string]bool{
+	"snmp.community":            true,
+	"snmp.auth_password":        true,
+	"snmp.priv_password":        true,
+	"snmp.community_candidates": true,
+}
+
+// DiffAgentSettings compares the settings an agent reports it is running with
+// against the policy resolved for it and returns the fleet-managed fields that
+// differ, sorted by path. Agent-local sections are not compared.
+func DiffAgentSettings(expected, reported pmsettings.Settings) ([]storage.SettingsDriftField, error) {
+	want, err := flattenDriftSettings(expected)
+	if err != nil {
+		return nil, err
+	}
+	got, err := flattenDriftSettings(reported)
+	if err != nil {
+		return nil, err
+	}
+
+	paths := make([]string, 0, len(want))
+	for path := range want {
+		paths = append(paths, path)
+	}
+	for path := range got {
+		if _, ok := want[path]; !ok {
+			paths = append(paths, path)
+		}
+	}
+	sort.Strings(paths)
+
+	var fields []storage.SettingsDriftField
+	for _, path := range paths {
+		if driftIgnoredPaths[path] || reflect.DeepEqual(want[path], got[path]) {
+			continue
+		}
+		field := storage.SettingsDriftField{Path: path}
+		if driftSecretPaths[path] {
+			field.Redacted = true
+		} else {
+			field.Expected = want[path]
+			field.Reported = got[path]
+		}
+		fields = append(fields, field)
+	}
+	return fields, nil
+}
+
+func flattenDriftSettings(cfg pmsettings.Settings) (map[string]interface{}, error) {
+	pmsettings.Sanitize(&cfg)
+	data, err := json.Marshal(cfg)
+	if err != nil {
+		return nil, err
+	}
+	var raw map[string]interface{}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, err
+	}
+	// Agent-local sections are not part of policy
+	delete(raw, "logging")
+	delete(raw, "web")
+	delete(raw, "notifications")
+
+	out := make(map[string]interface{})
+	var walk func(map[string]interface{}, string)
+	walk = func(curr map[string]interface{}, prefix string) {
+		for k, v := range curr {
+			key := k
+			if prefix != "" {
+				key = prefix + "." + k
+			}
+			if child, ok := v.(map[string]interface{}); ok {
+				walk(child, key)
+			} else {
+				out[key] = v
+			}
+		}
+	}
+	walk(raw, "")
+	return out, nil
+}
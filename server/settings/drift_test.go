@@ -0,0 +1,31 @@
+package settings
+
+import (
+	"testing"
+
+	pmsettings "printmaster/common/settings"
+)
+
+func TestDiffAgentSettings(t *testing.T) {
+	expected := pmsettings.DefaultSettings()
+	reported := expected
+	reported.Discovery.RangesText = "192.168.1.0/24"
+	reported.Web.HTTPPort = "9090"
+	fields, err := DiffAgentSettings(expected, reported)
+	if err != nil || len(fields) != 0 {
+		t.Fatalf("per-agent and agent-local fields must not drift: %+v, %v", fields, err)
+	}
+
+	reported.Discovery.SNMPEnabled = !expected.Discovery.SNMPEnabled
+	reported.SNMP.Community = "private"
+	fields, err = DiffAgentSettings(expected, reported)
+	if err != nil || len(fields) != 2 {
+		t.Fatalf("expected 2 drifted fields, got %+v, %v", fields, err)
+	}
+	if fields[0].Path != "discovery.snmp_enabled" || fields[0].Reported != reported.Discovery.SNMPEnabled {
+		t.Fatalf("unexpected field: %+v", fields[0])
+	}
+	if f := fields[1]; f.Path != "snmp.community" || !f.Redacted || f.Expected != nil || f.Reported != nil {
+		t.Fatalf("community drift must be redacted: %+v", f)
+	}
+}
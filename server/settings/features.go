@@ -0,0 +1,280 @@
+package settings
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	pmsettings "printmaster/common/settings"
+	authz "printmaster/server/authz"
+	"printmaster/server/storage"
+	"printmaster/server/tenancy"
+)
+
+// Feature flag sources, from lowest to highest precedence.
+const (
+	FeatureSourceDefault = "default" // Built-in default
+	FeatureSourceGlobal  = "global"  // Server-wide default set by an admin
+	FeatureSourceTenant  = "tenant"  // Tenant override
+)
+
+// FeatureFlagState is the resolved value of one flag for a scope.
+type FeatureFlagState struct {
+	pmsettings.FeatureFlagDefinition
+	Enabled bool   `json:"enabled"`
+	Source  string `json:"source"`
+	// Override is the explicit value stored at the requested scope, if any
+	Override  *bool      `json:"override,omitempty"`
+	UpdatedAt *time.Time `json:"updated_at,omitempty"`
+	UpdatedBy string     `json:"updated_by,omitempty"`
+}
+
+// FeatureFlagsSnapshot lists every flag resolved for the server default
+// (empty TenantID) or a tenant.
+type FeatureFlagsSnapshot struct {
+	TenantID string             `json:"tenant_id,omitempty"`
+	Flags    []FeatureFlagState `json:"flags"`
+}
+
+// Values returns the resolved flags keyed by flag name.
+func (s FeatureFlagsSnapshot) Values() map[string]bool {
+	values := make(map[string]bool, len(s.Flags))
+	for _, f := range s.Flags {
+		values[f.Key] = f.Enabled
+	}
+	return values
+}
+
+// ResolveFeatureFlags merges built-in defaults, server-wide defaults and (when
+// tenantID is set) the tenant's overrides.
+func (r *Resolver) ResolveFeatureFlags(ctx context.Context, tenantID string) (FeatureFlagsSnapshot, error) {
+	tenantID = strings.TrimSpace(tenantID)
+	global, err := r.store.ListFeatureFlags(ctx, "")
+	if err != nil {
+		return FeatureFlagsSnapshot{}, err
+	}
+	var tenant []*storage.FeatureFlag
+	if tenantID != "" {
+		if tenant, err = r.store.ListFeatureFlags(ctx, tenantID); err != nil {
+			return FeatureFlagsSnapshot{}, err
+		}
+	}
+	byKey := func(rows []*storage.FeatureFlag) map[string]*storage.FeatureFlag {
+		m := make(map[string]*storage.FeatureFlag, len(rows))
+		for _, row := range rows {
+			m[row.Key] = row
+		}
+		return m
+	}
+	globalByKey, tenantByKey := byKey(global), byKey(tenant)
+
+	snap := FeatureFlagsSnapshot{TenantID: tenantID, Flags: make([]FeatureFlagState, 0, len(pmsettings.FeatureFlagDefinitions))}
+	for _, def := range pmsettings.FeatureFlagDefinitions {
+		state := FeatureFlagState{FeatureFlagDefinition: def, Enabled: def.Default, Source: FeatureSourceDefault}
+		if row, ok := globalByKey[def.Key]; ok {
+			state.Enabled, state.Source = row.Enabled, FeatureSourceGlobal
+		}
+		if row, ok := tenantByKey[def.Key]; ok {
+			state.Enabled, state.Source = row.Enabled, FeatureSourceTenant
+		}
+		scoped := globalByKey
+		if tenantID != "" {
+			scoped = tenantByKey
+		}
+		if row, ok := scoped[def.Key]; ok {
+			enabled, updatedAt := row.Enabled, row.UpdatedAt
+			state.Override = &enabled
+			state.UpdatedAt = &updatedAt
+			state.UpdatedBy = row.UpdatedBy
+		}
+		snap.Flags = append(snap.Flags, state)
+	}
+	return snap, nil
+}
+
+// ResolveAgentFeatureFlags resolves the flags that apply to an agent (its
+// tenant's flags, or the server defaults for unassigned agents).
+func (r *Resolver) ResolveAgentFeatureFlags(ctx context.Context, agentID string) (map[string]bool, error) {
+	agent, err := r.store.GetAgent(ctx, strings.TrimSpace(agentID))
+	if err != nil {
+		return nil, err
+	}
+	tenantID := ""
+	if agent != nil {
+		tenantID = agent.TenantID
+	}
+	snap, err := r.ResolveFeatureFlags(ctx, tenantID)
+	if err != nil {
+		return nil, err
+	}
+	return snap.Values(), nil
+}
+
+// registerFeatureFlagRoutes wires the feature flag endpoints:
+//
+//	/api/v1/settings/features                    server-wide defaults
+//	/api/v1/settings/features/tenants/{id}       tenant overrides
+//	/api/v1/tenants/{id}/features                tenant alias
+func (api *API) registerFeatureFlagRoutes(mux *http.ServeMux, tenantAlias bool) {
+	mux.HandleFunc("/api/v1/settings/features", api.wrap(func(w http.ResponseWriter, r *http.Request) {
+		api.handleFeatureFlags(w, r, "")
+	}))
+	mux.HandleFunc("/api/v1/settings/features/tenants/", api.wrap(func(w http.ResponseWriter, r *http.Request) {
+		tenantID := strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/v1/settings/features/tenants/"), "/")
+		if tenantID == "" || strings.Contains(tenantID, "/") {
+			http.NotFound(w, r)
+			return
+		}
+		api.handleFeatureFlags(w, r, tenantID)
+	}))
+	if tenantAlias {
+		tenancy.RegisterTenantSubresource("features", func(w http.ResponseWriter, r *http.Request, tenantID, rest string) {
+			if strings.Trim(rest, "/") != "" {
+				http.NotFound(w, r)
+				return
+			}
+			api.wrap(func(w http.ResponseWriter, r *http.Request) {
+				api.handleFeatureFlags(w, r, tenantID)
+			})(w, r)
+		})
+	}
+}
+
+// handleFeatureFlags serves GET (resolved flags), PUT {"flags": {key: bool|null}}
+// (null clears an explicit value) and DELETE (clear every tenant override).
+// Changing flags requires a server admin; tenant users may only read them.
+func (api *API) handleFeatureFlags(w http.ResponseWriter, r *http.Request, tenantID string) {
+	resource := authz.ResourceRef{}
+	if tenantID != "" {
+		resource.TenantIDs = []string{tenantID}
+	}
+	switch r.Method {
+	case http.MethodGet:
+		if !api.authorize(w, r, authz.ActionFeatureFlagsRead, resource) {
+			return
+		}
+	case http.MethodPut, http.MethodDelete:
+		if !api.authorize(w, r, authz.ActionFeatureFlagsWrite, resource) {
+			return
+		}
+		if tenantID != "" {
+			if err := api.ensureTenantExists(r.Context(), tenantID); err != nil {
+				if errors.Is(err, sql.ErrNoRows) {
+					writeError(w, http.StatusNotFound, "tenant not found")
+					return
+				}
+				writeStoreError(w, err)
+				return
+			}
+		}
+		if r.Method == http.MethodPut {
+			if !api.saveFeatureFlags(w, r, tenantID) {
+				return
+			}
+		} else if tenantID == "" {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		} else if !api.clearTenantFeatureFlags(w, r, tenantID) {
+			return
+		}
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	snap, err := api.resolver.ResolveFeatureFlags(r.Context(), tenantID)
+	if err != nil {
+		writeStoreError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, snap)
+}
+
+func (api *API) saveFeatureFlags(w http.ResponseWriter, r *http.Request, tenantID string) bool {
+	var body struct {
+		Flags map[string]*bool `json:"flags"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid json")
+		return false
+	}
+	if len(body.Flags) == 0 {
+		writeError(w, http.StatusBadRequest, "flags required")
+		return false
+	}
+	keys := make([]string, 0, len(body.Flags))
+	for key := range body.Flags {
+		if !pmsettings.IsKnownFeatureFlag(key) {
+			writeError(w, http.StatusBadRequest, fmt.Sprintf("unknown feature flag %q", key))
+			return false
+		}
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	actor := api.actorLabel(r)
+	changes := make(map[string]interface{}, len(keys))
+	for _, key := range keys {
+		value := body.Flags[key]
+		var err error
+		if value == nil {
+			err = api.store.DeleteFeatureFlag(r.Context(), tenantID, key)
+			changes[key] = "inherit"
+		} else {
+			err = api.store.SetFeatureFlag(r.Context(), &storage.FeatureFlag{
+				TenantID:  tenantID,
+				Key:       key,
+				Enabled:   *value,
+				UpdatedBy: actor,
+			})
+			changes[key] = *value
+		}
+		if err != nil {
+			writeStoreError(w, err)
+			return false
+		}
+	}
+
+	scope := "server default"
+	if tenantID != "" {
+		scope = "tenant " + tenantID
+	}
+	api.audit(r, &storage.AuditEntry{
+		Action:     "feature_flags.update",
+		TargetType: "feature_flags",
+		TargetID:   featureFlagsTargetID(tenantID),
+		TenantID:   tenantID,
+		Details:    fmt.Sprintf("Updated %d feature flag(s) for %s", len(keys), scope),
+		Metadata:   map[string]interface{}{"flags": changes},
+	})
+	return true
+}
+
+func (api *API) clearTenantFeatureFlags(w http.ResponseWriter, r *http.Request, tenantID string) bool {
+	for _, def := range pmsettings.FeatureFlagDefinitions {
+		if err := api.store.DeleteFeatureFlag(r.Context(), tenantID, def.Key); err != nil {
+			writeStoreError(w, err)
+			return false
+		}
+	}
+	api.audit(r, &storage.AuditEntry{
+		Action:     "feature_flags.reset",
+		TargetType: "feature_flags",
+		TargetID:   featureFlagsTargetID(tenantID),
+		TenantID:   tenantID,
+		Details:    fmt.Sprintf("Cleared feature flag overrides for tenant %s", tenantID),
+	})
+	return true
+}
+
+func featureFlagsTargetID(tenantID string) string {
+	if tenantID == "" {
+		return "global"
+	}
+	return tenantID
+}
@@ -0,0 +1,182 @@
+package settings
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	pmsettings "printmaster/common/settings"
+	authz "printmaster/server/authz"
+	"printmaster/server/storage"
+)
+
+func TestResolveFeatureFlagsPrecedence(t *testing.T) {
+	store := newFakeStore()
+	store.tenants["tenant-a"] = &storage.Tenant{ID: "tenant-a"}
+	_ = store.SetFeatureFlag(context.Background(), &storage.FeatureFlag{Key: pmsettings.FeatureRemoteProxy, Enabled: false})
+	_ = store.SetFeatureFlag(context.Background(), &storage.FeatureFlag{TenantID: "tenant-a", Key: pmsettings.FeatureRemoteProxy, Enabled: true})
+	_ = store.SetFeatureFlag(context.Background(), &storage.FeatureFlag{TenantID: "tenant-a", Key: pmsettings.FeatureJobAccounting, Enabled: false})
+
+	resolver, err := NewResolver(store)
+	if err != nil {
+		t.Fatalf("NewResolver failed: %v", err)
+	}
+
+	global, err := resolver.ResolveFeatureFlags(context.Background(), "")
+	if err != nil {
+		t.Fatalf("resolve global: %v", err)
+	}
+	if values := global.Values(); values[pmsettings.FeatureRemoteProxy] || !values[pmsettings.FeatureJobAccounting] {
+		t.Fatalf("unexpected global flags: %+v", values)
+	}
+
+	tenant, err := resolver.ResolveFeatureFlags(context.Background(), "tenant-a")
+	if err != nil {
+		t.Fatalf("resolve tenant: %v", err)
+	}
+	for _, f := range tenant.Flags {
+		switch f.Key {
+		case pmsettings.FeatureRemoteProxy:
+			if !f.Enabled || f.Source != FeatureSourceTenant || f.Override == nil {
+				t.Fatalf("tenant override should win: %+v", f)
+			}
+		case pmsettings.FeatureJobAccounting:
+			if f.Enabled || f.Source != FeatureSourceTenant {
+				t.Fatalf("job accounting should be off for tenant: %+v", f)
+			}
+		case pmsettings.FeatureFirmwareUpdates:
+			if !f.Enabled || f.Source != FeatureSourceDefault || f.Override != nil {
+				t.Fatalf("firmware updates should use the default: %+v", f)
+			}
+		}
+	}
+}
+
+func TestAPIFeatureFlagsPutAndClear(t *testing.T) {
+	store := newFakeStore()
+	store.tenants["tenant-a"] = &storage.Tenant{ID: "tenant-a"}
+	var audited []*storage.AuditEntry
+	api, err := NewAPI(store, nil, APIOptions{
+		Authorizer:    allowAllAuthorizer,
+		ActorResolver: func(*http.Request) string { return "admin" },
+		AuditLogger:   func(_ *http.Request, entry *storage.AuditEntry) { audited = append(audited, entry) },
+	})
+	if err != nil {
+		t.Fatalf("NewAPI failed: %v", err)
+	}
+
+	req := httptest.NewRequest(http.MethodPut, "/api/v1/settings/features/tenants/tenant-a",
+		strings.NewReader(`{"flags":{"firmware_updates":false}}`))
+	rr := httptest.NewRecorder()
+	api.handleFeatureFlags(rr, req, "tenant-a")
+	if rr.Code != http.StatusOK {
+		t.Fatalf("put: %d %s", rr.Code, rr.Body.String())
+	}
+	var snap FeatureFlagsSnapshot
+	if err := json.Unmarshal(rr.Body.Bytes(), &snap); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if snap.Values()[pmsettings.FeatureFirmwareUpdates] {
+		t.Fatalf("firmware updates should be disabled: %+v", snap)
+	}
+	if flag := store.featureFlags["tenant-a"][pmsettings.FeatureFirmwareUpdates]; flag == nil || flag.UpdatedBy != "admin" {
+		t.Fatalf("flag not stored with actor: %+v", flag)
+	}
+	if len(audited) != 1 || audited[0].Action != "feature_flags.update" {
+		t.Fatalf("expected audit entry, got %+v", audited)
+	}
+
+	req = httptest.NewRequest(http.MethodDelete, "/api/v1/settings/features/tenants/tenant-a", nil)
+	rr = httptest.NewRecorder()
+	api.handleFeatureFlags(rr, req, "tenant-a")
+	if rr.Code != http.StatusOK {
+		t.Fatalf("delete: %d", rr.Code)
+	}
+	if len(store.featureFlags["tenant-a"]) != 0 {
+		t.Fatalf("tenant overrides should be cleared")
+	}
+}
+
+func TestAPIFeatureFlagsValidation(t *testing.T) {
+	store := newFakeStore()
+	api, err := NewAPI(store, nil, APIOptions{Authorizer: allowAllAuthorizer})
+	if err != nil {
+		t.Fatalf("NewAPI failed: %v", err)
+	}
+
+	req := httptest.NewRequest(http.MethodPut, "/api/v1/settings/features", strings.NewReader(`{"flags":{"bogus":true}}`))
+	rr := httptest.NewRecorder()
+	api.handleFeatureFlags(rr, req, "")
+	if rr.Code != http.StatusBadRequest {
+		t.Fatalf("unknown flag: %d", rr.Code)
+	}
+
+	req = httptest.NewRequest(http.MethodPut, "/api/v1/settings/features/tenants/missing", strings.NewReader(`{"flags":{"remote_proxy":false}}`))
+	rr = httptest.NewRecorder()
+	api.handleFeatureFlags(rr, req, "missing")
+	if rr.Code != http.StatusNotFound {
+		t.Fatalf("missing tenant: %d", rr.Code)
+	}
+}
+
+func TestAPIFeatureFlagsWriteRequiresPermission(t *testing.T) {
+	store := newFakeStore()
+	api, err := NewAPI(store, nil, APIOptions{
+		Authorizer: func(_ *http.Request, action authz.Action, _ authz.ResourceRef) error {
+			if action == authz.ActionFeatureFlagsWrite {
+				return authz.ErrForbidden
+			}
+			return nil
+		},
+	})
+	if err != nil {
+		t.Fatalf("NewAPI failed: %v", err)
+	}
+
+	rr := httptest.NewRecorder()
+	api.handleFeatureFlags(rr, httptest.NewRequest(http.MethodGet, "/api/v1/settings/features", nil), "")
+	if rr.Code != http.StatusOK {
+		t.Fatalf("read: %d", rr.Code)
+	}
+	rr = httptest.NewRecorder()
+	api.handleFeatureFlags(rr, httptest.NewRequest(http.MethodPut, "/api/v1/settings/features",
+		strings.NewReader(`{"flags":{"remote_proxy":false}}`)), "")
+	if rr.Code != http.StatusForbidden {
+		t.Fatalf("write: %d", rr.Code)
+	}
+}
+
+func TestBuildAgentSnapshotAppliesFeatureFlags(t *testing.T) {
+	store := newFakeStore()
+	cfg := pmsettings.DefaultSettings()
+	cfg.Spooler.Enabled = true
+	store.global = &storage.SettingsRecord{SchemaVersion: "v1", Settings: cfg}
+	store.tenants["tenant-a"] = &storage.Tenant{ID: "tenant-a"}
+	resolver, err := NewResolver(store)
+	if err != nil {
+		t.Fatalf("NewResolver failed: %v", err)
+	}
+
+	before, err := BuildAgentSnapshot(context.Background(), resolver, "tenant-a", "")
+	if err != nil {
+		t.Fatalf("build snapshot: %v", err)
+	}
+	if !before.FeatureFlags[pmsettings.FeatureJobAccounting] || !before.Settings.Spooler.Enabled {
+		t.Fatalf("defaults should leave job accounting on: %+v", before.FeatureFlags)
+	}
+
+	_ = store.SetFeatureFlag(context.Background(), &storage.FeatureFlag{TenantID: "tenant-a", Key: pmsettings.FeatureJobAccounting, Enabled: false})
+	after, err := BuildAgentSnapshot(context.Background(), resolver, "tenant-a", "")
+	if err != nil {
+		t.Fatalf("build snapshot: %v", err)
+	}
+	if after.FeatureFlags[pmsettings.FeatureJobAccounting] || after.Settings.Spooler.Enabled {
+		t.Fatalf("job accounting flag should disable the spooler")
+	}
+	if after.Version == before.Version {
+		t.Fatalf("changing a flag should change the snapshot version")
+	}
+}
@@ -21,6 +21,10 @@ type Store interface {
 	GetAgentSettings(context.Context, string) (*storage.AgentSettingsRecord, error)
 	UpsertAgentSettings(context.Context, *storage.AgentSettingsRecord) error
 	DeleteAgentSettings(context.Context, string) error
+	ListFeatureFlags(context.Context, string) ([]*storage.FeatureFlag, error)
+	SetFeatureFlag(context.Context, *storage.FeatureFlag) error
+	DeleteFeatureFlag(context.Context, string, string) error
+	ListDetectionRules(context.Context) ([]*storage.DetectionRule, error)
 	GetAgent(context.Context, string) (*storage.Agent, error)
 	GetTenant(context.Context, string) (*storage.Tenant, error)
 }
@@ -0,0 +1,52 @@
+package settings
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	pmsettings "printmaster/common/settings"
+	"printmaster/server/storage"
+)
+
+// snmpSweepChanged reports whether the SNMP community sweep settings differ.
+// Operators may edit the rest of the SNMP section, but the sweep and its
+// candidate communities are reserved for admins.
+func snmpSweepChanged(before, after pmsettings.Settings) bool {
+	pmsettings.Sanitize(&before)
+	pmsettings.Sanitize(&after)
+	b, a := before.SNMP, after.SNMP
+	return b.CommunitySweepEnabled != a.CommunitySweepEnabled ||
+		b.CommunitySweepPerSecond != a.CommunitySweepPerSecond ||
+		strings.Join(pmsettings.CommunityCandidateList(b.CommunityCandidates), "\n") !=
+			strings.Join(pmsettings.CommunityCandidateList(a.CommunityCandidates), "\n")
+}
+
+// overridesTouchSNMPSweep reports whether an override map sets any sweep key.
+func overridesTouchSNMPSweep(overrides map[string]interface{}) bool {
+	for _, key := range collectOverrideKeys(overrides) {
+		switch key {
+		case "snmp.community_sweep_enabled", "snmp.community_candidates", "snmp.community_sweep_per_second":
+			return true
+		}
+	}
+	return false
+}
+
+// auditSNMPSweep records a change to the community sweep settings. Candidate
+// communities are counted, never logged.
+func (api *API) auditSNMPSweep(r *http.Request, targetID, tenantID string, snmp pmsettings.SNMPSettings) {
+	api.audit(r, &storage.AuditEntry{
+		Action:     "settings.snmp_sweep.update",
+		TargetType: "settings",
+		TargetID:   targetID,
+		TenantID:   tenantID,
+		Severity:   storage.AuditSeverityWarn,
+		Details:    fmt.Sprintf("SNMP community sweep for %s set to enabled=%t", targetID, snmp.CommunitySweepEnabled),
+		Metadata: map[string]interface{}{
+			"enabled":         snmp.CommunitySweepEnabled,
+			"candidate_count": len(pmsettings.CommunityCandidateList(snmp.CommunityCandidates)),
+			"per_second":      snmp.CommunitySweepPerSecond,
+		},
+	})
+}
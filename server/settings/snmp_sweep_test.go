@@ -0,0 +1,62 @@
+package settings
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	pmsettings "printmaster/common/settings"
+	authz "printmaster/server/authz"
+	"printmaster/server/storage"
+)
+
+func TestAPITenantSNMPSweepRequiresAdmin(t *testing.T) {
+	store := newFakeStore()
+	store.global = &storage.SettingsRecord{SchemaVersion: "v1", Settings: pmsettings.DefaultSettings()}
+	store.tenants["tenant-a"] = &storage.Tenant{ID: "tenant-a", Name: "Tenant A"}
+	var audits []*storage.AuditEntry
+	api, err := NewAPI(store, nil, APIOptions{
+		Authorizer: func(_ *http.Request, action authz.Action, _ authz.ResourceRef) error {
+			if action == authz.ActionSettingsSNMPSweepWrite {
+				return authz.ErrForbidden
+			}
+			return nil
+		},
+		AuditLogger: func(_ *http.Request, entry *storage.AuditEntry) { audits = append(audits, entry) },
+	})
+	if err != nil {
+		t.Fatalf("NewAPI failed: %v", err)
+	}
+	put := func(patch map[string]interface{}) int {
+		body, _ := json.Marshal(patch)
+		req := httptest.NewRequest(http.MethodPut, "/api/v1/settings/tenants/tenant-a", bytes.NewReader(body))
+		rr := httptest.NewRecorder()
+		api.handleTenantSettings(rr, req, "tenant-a")
+		return rr.Code
+	}
+
+	sweep := map[string]interface{}{"snmp": map[string]interface{}{
+		"community_sweep_enabled": true,
+		"community_candidates":    "public\nsecret",
+	}}
+	if code := put(sweep); code != http.StatusForbidden {
+		t.Fatalf("operators must not enable the sweep, got %d", code)
+	}
+	if code := put(map[string]interface{}{"snmp": map[string]interface{}{"timeout_ms": 3000}}); code != http.StatusOK {
+		t.Fatalf("other SNMP settings should stay editable, got %d", code)
+	}
+
+	api.authorizer = allowAllAuthorizer
+	if code := put(sweep); code != http.StatusOK {
+		t.Fatalf("admin sweep update failed: %d", code)
+	}
+	last := audits[len(audits)-1]
+	if last.Action != "settings.snmp_sweep.update" || last.Metadata["candidate_count"] != 2 {
+		t.Fatalf("unexpected sweep audit: %+v", last)
+	}
+	if bytes.Contains([]byte(last.Details), []byte("secret")) {
+		t.Fatalf("audit must not contain candidate communities: %q", last.Details)
+	}
+}
@@ -0,0 +1,473 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	authz "printmaster/server/authz"
+	"printmaster/server/storage"
+)
+
+// defaultShareLinkTTL applies when a share link is created without an expiry.
+const defaultShareLinkTTL = 7 * 24 * time.Hour
+
+// shareLinkRequest creates a share link. ExpiresInHours defaults to a week
+// and is capped at storage.MaxShareLinkTTL.
+type shareLinkRequest struct {
+	TenantID       string                  `json:"tenant_id"`
+	Kind           string                  `json:"kind"`
+	Name           string                  `json:"name"`
+	Filter         storage.ShareLinkFilter `json:"filter"`
+	ReportRunID    *int64                  `json:"report_run_id,omitempty"`
+	ExpiresInHours int                     `json:"expires_in_hours"`
+	Password       string                  `json:"password,omitempty"`
+}
+
+// handleShareLinks lists share links for the caller's tenants or creates one.
+func handleShareLinks(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	switch r.Method {
+	case http.MethodGet:
+		if !authorizeOrReject(w, r, authz.ActionShareLinksRead, authz.ResourceRef{}) {
+			return
+		}
+		principal := getPrincipal(r)
+		var tenantIDs []string
+		if principal != nil && !principal.IsAdmin() {
+			tenantIDs = principal.AllowedTenantIDs()
+			if len(tenantIDs) == 0 {
+				writeShareLinks(w, nil)
+				return
+			}
+		}
+		if tid := strings.TrimSpace(r.URL.Query().Get("tenant_id")); tid != "" {
+			if !authorizeOrReject(w, r, authz.ActionShareLinksRead, authz.ResourceRef{TenantIDs: []string{tid}}) {
+				return
+			}
+			tenantIDs = []string{tid}
+		}
+		links, err := serverStore.ListShareLinks(ctx, tenantIDs)
+		if err != nil {
+			logError("Failed to list share links", "error", err)
+			http.Error(w, "failed to list share links", http.StatusInternalServerError)
+			return
+		}
+		writeShareLinks(w, links)
+	case http.MethodPost:
+		var req shareLinkRequest
+		if err := decodeJSONBody(r, &req); err != nil {
+			http.Error(w, "invalid JSON", http.StatusBadRequest)
+			return
+		}
+		req.TenantID = strings.TrimSpace(req.TenantID)
+		if req.TenantID == "" {
+			http.Error(w, "tenant_id is required", http.StatusBadRequest)
+			return
+		}
+		if !authorizeOrReject(w, r, authz.ActionShareLinksWrite, authz.ResourceRef{TenantIDs: []string{req.TenantID}}) {
+			return
+		}
+		createShareLink(w, r, req)
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func createShareLink(w http.ResponseWriter, r *http.Request, req shareLinkRequest) {
+	ctx := r.Context()
+	tenant, err := serverStore.GetTenant(ctx, req.TenantID)
+	if err != nil || tenant == nil {
+		http.Error(w, "tenant not found", http.StatusBadRequest)
+		return
+	}
+
+	ttl := defaultShareLinkTTL
+	if req.ExpiresInHours < 0 {
+		http.Error(w, "expires_in_hours must be positive", http.StatusBadRequest)
+		return
+	}
+	if req.ExpiresInHours > 0 {
+		ttl = time.Duration(req.ExpiresInHours) * time.Hour
+	}
+	if ttl > storage.MaxShareLinkTTL {
+		http.Error(w, fmt.Sprintf("share links can be valid for at most %d hours", int(storage.MaxShareLinkTTL.Hours())), http.StatusBadRequest)
+		return
+	}
+
+	_, _, actorName, _ := auditActorFromPrincipal(r)
+	link := &storage.ShareLink{
+		TenantID:    req.TenantID,
+		Kind:        strings.ToLower(strings.TrimSpace(req.Kind)),
+		Name:        req.Name,
+		Filter:      req.Filter,
+		ReportRunID: req.ReportRunID,
+		ExpiresAt:   time.Now().UTC().Add(ttl),
+		CreatedBy:   actorName,
+	}
+	if err := link.Validate(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if link.Kind == storage.ShareLinkKindDevices && len(link.Filter.AgentIDs) > 0 {
+		if !shareAgentsInTenant(ctx, link.TenantID, link.Filter.AgentIDs) {
+			http.Error(w, "filter.agent_ids must belong to the tenant", http.StatusBadRequest)
+			return
+		}
+	}
+	if link.Kind == storage.ShareLinkKindReport {
+		if _, _, err := loadSharedReportRun(ctx, link); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	token, err := serverStore.CreateShareLink(ctx, link, req.Password)
+	if err != nil {
+		logError("Failed to create share link", "tenant", link.TenantID, "error", err)
+		http.Error(w, "failed to create share link", http.StatusInternalServerError)
+		return
+	}
+	auditShareLink(r, "share_link.create", link, fmt.Sprintf("kind=%s tenant=%s expires=%s password=%t",
+		link.Kind, link.TenantID, link.ExpiresAt.Format(time.RFC3339), link.HasPassword))
+
+	scheme := "https"
+	if r.TLS == nil {
+		scheme = "http"
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"link":  link,
+		"token": token,
+		"url":   fmt.Sprintf("%s://%s/share/%s", scheme, r.Host, token),
+	})
+}
+
+// handleShareLink returns or revokes a single share link.
+func handleShareLink(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	id, err := strconv.ParseInt(strings.TrimPrefix(r.URL.Path, "/api/v1/share-links/"), 10, 64)
+	if err != nil {
+		http.Error(w, "invalid share link id", http.StatusBadRequest)
+		return
+	}
+	link, err := serverStore.GetShareLink(ctx, id)
+	if err != nil {
+		logError("Failed to load share link", "id", id, "error", err)
+		http.Error(w, "failed to load share link", http.StatusInternalServerError)
+		return
+	}
+	if link == nil {
+		http.Error(w, "share link not found", http.StatusNotFound)
+		return
+	}
+	resource := authz.ResourceRef{TenantIDs: []string{link.TenantID}}
+
+	switch r.Method {
+	case http.MethodGet:
+		if !authorizeOrReject(w, r, authz.ActionShareLinksRead, resource) {
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(link)
+	case http.MethodDelete:
+		if !authorizeOrReject(w, r, authz.ActionShareLinksWrite, resource) {
+			return
+		}
+		_, _, actorName, _ := auditActorFromPrincipal(r)
+		if err := serverStore.RevokeShareLink(ctx, id, actorName); err != nil {
+			logError("Failed to revoke share link", "id", id, "error", err)
+			http.Error(w, "failed to revoke share link", http.StatusInternalServerError)
+			return
+		}
+		auditShareLink(r, "share_link.revoke", link, "")
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func writeShareLinks(w http.ResponseWriter, links []*storage.ShareLink) {
+	if links == nil {
+		links = []*storage.ShareLink{}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(links)
+}
+
+func auditShareLink(r *http.Request, action string, link *storage.ShareLink, details string) {
+	actorType, actorID, actorName, actorTenant := auditActorFromPrincipal(r)
+	logInfo("Share link change", "action", action, "id", link.ID, "tenant", link.TenantID, "actor", actorName)
+	logAuditEntry(r.Context(), &storage.AuditEntry{
+		ActorType:  actorType,
+		ActorID:    actorID,
+		ActorName:  actorName,
+		TenantID:   actorTenant,
+		Action:     action,
+		TargetType: "share_link",
+		TargetID:   strconv.FormatInt(link.ID, 10),
+		Details:    details,
+		IPAddress:  extractClientIP(r),
+		UserAgent:  r.Header.Get("User-Agent"),
+	})
+}
+
+// ============================================================
+// Public share views
+// ============================================================
+
+// sharedDevice is the read-only projection of a device shown on share
+// links. Network details and raw data stay private.
+type sharedDevice struct {
+	Serial        string                 `json:"serial"`
+	Manufacturer  string                 `json:"manufacturer,omitempty"`
+	Model         string                 `json:"model,omitempty"`
+	AssetNumber   string                 `json:"asset_number,omitempty"`
+	Location      string                 `json:"location,omitempty"`
+	StatusState   string                 `json:"status_state,omitempty"`
+	PageCount     int                    `json:"page_count,omitempty"`
+	MonoPages     int                    `json:"mono_pages,omitempty"`
+	ColorPages    int                    `json:"color_pages,omitempty"`
+	TonerLevels   map[string]interface{} `json:"toner_levels,omitempty"`
+	LastSeen      time.Time              `json:"last_seen"`
+	LastMetricsAt *time.Time             `json:"last_metrics_at,omitempty"`
+}
+
+// handleSharePage serves the public share viewer. The token is read from the
+// path by the page script.
+func handleSharePage(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "only GET allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	content, err := webFS.ReadFile("web/share.html")
+	if err != nil {
+		logWarn("Share page not found", "err", err)
+		http.NotFound(w, r)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Referrer-Policy", "no-referrer")
+	w.Write(content)
+}
+
+// handleShareView returns the snapshot behind a share link. It is public:
+// the token authorizes the request, plus the X-Share-Password header when
+// the link has a password.
+func handleShareView(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "GET only", http.StatusMethodNotAllowed)
+		return
+	}
+	ctx := r.Context()
+	token := strings.TrimPrefix(r.URL.Path, "/api/v1/share/")
+	clientIP := extractClientIP(r)
+	limiterKey := "share:" + safeTokenPrefix(token)
+	if authRateLimiter != nil {
+		if blocked, until := authRateLimiter.IsBlocked(clientIP, limiterKey); blocked {
+			w.Header().Set("Retry-After", strconv.Itoa(int(time.Until(until).Seconds())+1))
+			http.Error(w, "too many attempts", http.StatusTooManyRequests)
+			return
+		}
+	}
+
+	link, err := serverStore.GetShareLinkByToken(ctx, token)
+	if err != nil {
+		logError("Failed to load share link", "error", err)
+		http.Error(w, "failed to load share link", http.StatusInternalServerError)
+		return
+	}
+	if link == nil {
+		if authRateLimiter != nil {
+			authRateLimiter.RecordFailure(clientIP, limiterKey)
+		}
+		http.Error(w, "share link not found", http.StatusNotFound)
+		return
+	}
+	now := time.Now().UTC()
+	if !link.Active(now) {
+		http.Error(w, "share link has expired or was revoked", http.StatusGone)
+		return
+	}
+	if !link.CheckPassword(r.Header.Get("X-Share-Password")) {
+		if authRateLimiter != nil {
+			authRateLimiter.RecordFailure(clientIP, limiterKey)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusUnauthorized)
+		json.NewEncoder(w).Encode(map[string]interface{}{"password_required": true})
+		return
+	}
+	if authRateLimiter != nil {
+		authRateLimiter.RecordSuccess(clientIP, limiterKey)
+	}
+
+	tenantName := link.TenantID
+	if tenant, err := serverStore.GetTenant(ctx, link.TenantID); err == nil && tenant != nil && tenant.Name != "" {
+		tenantName = tenant.Name
+	}
+	resp := map[string]interface{}{
+		"name":         link.Name,
+		"kind":         link.Kind,
+		"tenant":       tenantName,
+		"expires_at":   link.ExpiresAt,
+		"generated_at": now,
+	}
+
+	switch link.Kind {
+	case storage.ShareLinkKindDevices:
+		devices, err := sharedDevices(ctx, link)
+		if err != nil {
+			logError("Failed to build shared device view", "id", link.ID, "error", err)
+			http.Error(w, "failed to load devices", http.StatusInternalServerError)
+			return
+		}
+		resp["devices"] = devices
+	case storage.ShareLinkKindReport:
+		report, run, err := loadSharedReportRun(ctx, link)
+		if err != nil {
+			http.Error(w, "shared report is no longer available", http.StatusGone)
+			return
+		}
+		resp["report"] = map[string]interface{}{
+			"name":         report.Name,
+			"type":         report.Type,
+			"format":       run.Format,
+			"completed_at": run.CompletedAt,
+			"row_count":    run.RowCount,
+			"content":      run.ResultData,
+		}
+	}
+
+	if err := serverStore.RecordShareLinkAccess(ctx, link.ID, now); err != nil {
+		logWarn("Failed to record share link access", "id", link.ID, "error", err)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	json.NewEncoder(w).Encode(resp)
+}
+
+// sharedDevices returns the tenant's devices matching the link filter.
+func sharedDevices(ctx context.Context, link *storage.ShareLink) ([]sharedDevice, error) {
+	agents, err := serverStore.ListAgents(ctx)
+	if err != nil {
+		return nil, err
+	}
+	wanted := make(map[string]bool, len(link.Filter.AgentIDs))
+	for _, id := range link.Filter.AgentIDs {
+		wanted[id] = true
+	}
+	allowed := make(map[string]bool)
+	for _, a := range agents {
+		if a == nil || a.TenantID != link.TenantID {
+			continue
+		}
+		if len(wanted) == 0 || wanted[a.AgentID] {
+			allowed[a.AgentID] = true
+		}
+	}
+	out := []sharedDevice{}
+	if len(allowed) == 0 {
+		return out, nil
+	}
+
+	devices, err := serverStore.ListAllDevices(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var matched []*storage.Device
+	for _, d := range devices {
+		if d != nil && allowed[d.AgentID] && shareFilterMatches(link.Filter, d) {
+			matched = append(matched, d)
+		}
+	}
+	for _, d := range enrichDevicesWithMetrics(ctx, matched) {
+		out = append(out, sharedDevice{
+			Serial:        d.Serial,
+			Manufacturer:  d.Manufacturer,
+			Model:         d.Model,
+			AssetNumber:   d.AssetNumber,
+			Location:      d.Location,
+			StatusState:   d.StatusState,
+			PageCount:     d.PageCount,
+			MonoPages:     d.MonoPages,
+			ColorPages:    d.ColorPages,
+			TonerLevels:   d.TonerLevels,
+			LastSeen:      d.LastSeen,
+			LastMetricsAt: d.LastMetricsAt,
+		})
+	}
+	return out, nil
+}
+
+func shareFilterMatches(f storage.ShareLinkFilter, d *storage.Device) bool {
+	contains := func(value, sub string) bool {
+		return sub == "" || strings.Contains(strings.ToLower(value), strings.ToLower(strings.TrimSpace(sub)))
+	}
+	if !contains(d.Manufacturer, f.Manufacturer) || !contains(d.Model, f.Model) || !contains(d.Location, f.Location) {
+		return false
+	}
+	if f.Search == "" {
+		return true
+	}
+	for _, v := range []string{d.Serial, d.Manufacturer, d.Model, d.AssetNumber, d.Location, d.Hostname} {
+		if contains(v, f.Search) {
+			return true
+		}
+	}
+	return false
+}
+
+// shareAgentsInTenant reports whether every agent ID belongs to tenantID.
+func shareAgentsInTenant(ctx context.Context, tenantID string, agentIDs []string) bool {
+	agents, err := serverStore.ListAgents(ctx)
+	if err != nil {
+		return false
+	}
+	inTenant := make(map[string]bool)
+	for _, a := range agents {
+		if a != nil && a.TenantID == tenantID {
+			inTenant[a.AgentID] = true
+		}
+	}
+	for _, id := range agentIDs {
+		if !inTenant[id] {
+			return false
+		}
+	}
+	return true
+}
+
+// loadSharedReportRun returns the report run behind a report link. The report
+// must be scoped to the link's tenant alone so a share never exposes another
+// tenant's data.
+func loadSharedReportRun(ctx context.Context, link *storage.ShareLink) (*storage.ReportDefinition, *storage.ReportRun, error) {
+	run, err := serverStore.GetReportRun(ctx, *link.ReportRunID)
+	if err != nil || run == nil {
+		return nil, nil, fmt.Errorf("report run not found")
+	}
+	if run.Status != storage.ReportRunStatusCompleted || run.ResultData == "" {
+		return nil, nil, fmt.Errorf("report run has no stored result to share")
+	}
+	report, err := serverStore.GetReport(ctx, run.ReportID)
+	if err != nil || report == nil {
+		return nil, nil, fmt.Errorf("report not found")
+	}
+	if len(report.TenantIDs) != 1 || report.TenantIDs[0] != link.TenantID {
+		return nil, nil, fmt.Errorf("report must be scoped to tenant %s only", link.TenantID)
+	}
+	return report, run, nil
+}
+
+// safeTokenPrefix returns the first characters of a token for rate limiting.
+func safeTokenPrefix(token string) string {
+	if len(token) > 8 {
+		return token[:8]
+	}
+	return token
+}
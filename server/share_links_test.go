@@ -0,0 +1,123 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"printmaster/server/storage"
+)
+
+func TestShareLinksLifecycle(t *testing.T) {
+	store := SetupTestStore(t)
+	ctx := context.Background()
+	for _, tenant := range []*storage.Tenant{{ID: "tenant-a", Name: "Acme"}, {ID: "tenant-b", Name: "Globex"}} {
+		if err := store.CreateTenant(ctx, tenant); err != nil {
+			t.Fatalf("CreateTenant: %v", err)
+		}
+	}
+	for _, a := range []*storage.Agent{
+		{AgentID: "agent-a", Hostname: "a", Token: "ta", TenantID: "tenant-a", Status: "active", RegisteredAt: time.Now(), LastSeen: time.Now()},
+		{AgentID: "agent-b", Hostname: "b", Token: "tb", TenantID: "tenant-b", Status: "active", RegisteredAt: time.Now(), LastSeen: time.Now()},
+	} {
+		if err := store.RegisterAgent(ctx, a); err != nil {
+			t.Fatalf("RegisterAgent: %v", err)
+		}
+	}
+	for _, d := range []struct{ serial, agent, model string }{
+		{"SN-A1", "agent-a", "HP M404"}, {"SN-A2", "agent-a", "Brother HL"}, {"SN-B1", "agent-b", "HP M404"},
+	} {
+		dev := &storage.Device{}
+		dev.Serial, dev.AgentID, dev.Model, dev.IP = d.serial, d.agent, d.model, "10.0.0.1"
+		dev.LastSeen = time.Now()
+		if err := store.UpsertDevice(ctx, dev); err != nil {
+			t.Fatalf("UpsertDevice: %v", err)
+		}
+	}
+
+	create := func(user *storage.User, body map[string]interface{}) *httptest.ResponseRecorder {
+		raw, _ := json.Marshal(body)
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/share-links", bytes.NewReader(raw))
+		req = InjectTestUser(req, user)
+		rr := httptest.NewRecorder()
+		handleShareLinks(rr, req)
+		return rr
+	}
+	view := func(token, password string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/share/"+token, nil)
+		if password != "" {
+			req.Header.Set("X-Share-Password", password)
+		}
+		rr := httptest.NewRecorder()
+		handleShareView(rr, req)
+		return rr
+	}
+
+	operator := NewTestUser(storage.RoleOperator, "tenant-a")
+	if rr := create(operator, map[string]interface{}{"tenant_id": "tenant-b", "kind": "devices"}); rr.Code != http.StatusForbidden {
+		t.Fatalf("expected 403 sharing another tenant, got %d", rr.Code)
+	}
+	if rr := create(NewTestUser(storage.RoleViewer, "tenant-a"), map[string]interface{}{"tenant_id": "tenant-a", "kind": "devices"}); rr.Code != http.StatusForbidden {
+		t.Fatalf("expected 403 for viewer, got %d", rr.Code)
+	}
+
+	rr := create(operator, map[string]interface{}{
+		"tenant_id": "tenant-a", "kind": "devices", "name": "Acme fleet",
+		"filter": map[string]string{"model": "hp"}, "expires_in_hours": 24, "password": "s3cret",
+	})
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("create: expected 201, got %d: %s", rr.Code, rr.Body.String())
+	}
+	var created struct {
+		Link  storage.ShareLink `json:"link"`
+		Token string            `json:"token"`
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &created); err != nil || created.Token == "" || !created.Link.HasPassword {
+		t.Fatalf("unexpected create response: %s", rr.Body.String())
+	}
+
+	if rr := view(created.Token, ""); rr.Code != http.StatusUnauthorized {
+		t.Fatalf("expected 401 without password, got %d", rr.Code)
+	}
+	rr = view(created.Token, "s3cret")
+	if rr.Code != http.StatusOK {
+		t.Fatalf("view: expected 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	var snapshot struct {
+		Tenant  string                   `json:"tenant"`
+		Devices []map[string]interface{} `json:"devices"`
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &snapshot); err != nil {
+		t.Fatalf("decode snapshot: %v", err)
+	}
+	if snapshot.Tenant != "Acme" || len(snapshot.Devices) != 1 || snapshot.Devices[0]["serial"] != "SN-A1" {
+		t.Fatalf("expected only the filtered tenant-a device, got %s", rr.Body.String())
+	}
+	if _, leaked := snapshot.Devices[0]["ip"]; leaked {
+		t.Fatal("shared devices must not expose IP addresses")
+	}
+
+	req := httptest.NewRequest(http.MethodDelete, "/api/v1/share-links/"+strconv.FormatInt(created.Link.ID, 10), nil)
+	req = InjectTestUser(req, operator)
+	rr = httptest.NewRecorder()
+	handleShareLink(rr, req)
+	if rr.Code != http.StatusNoContent {
+		t.Fatalf("revoke: expected 204, got %d: %s", rr.Code, rr.Body.String())
+	}
+	if rr := view(created.Token, "s3cret"); rr.Code != http.StatusGone {
+		t.Fatalf("expected 410 after revoke, got %d", rr.Code)
+	}
+	if rr := view("not-a-token", ""); rr.Code != http.StatusNotFound {
+		t.Fatalf("expected 404 for unknown token, got %d", rr.Code)
+	}
+
+	link, err := store.GetShareLink(ctx, created.Link.ID)
+	if err != nil || link == nil || link.AccessCount != 1 || link.RevokedAt == nil {
+		t.Fatalf("expected one recorded access and revocation, got %+v, %v", link, err)
+	}
+}
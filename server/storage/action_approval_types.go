@@ -0,0 +1,78 @@
+package storage
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Action approval states. Pending requests are decided once; approved ones
+// then finish as executed or failed.
+const (
+	ActionApprovalPending   = "pending"
+	ActionApprovalApproved  = "approved"  // approved, action running
+	ActionApprovalExecuted  = "executed"  // approved and carried out
+	ActionApprovalFailed    = "failed"    // approved but the action returned an error
+	ActionApprovalRejected  = "rejected"  // declined by an approver
+	ActionApprovalCancelled = "cancelled" // withdrawn by the requester
+	ActionApprovalExpired   = "expired"   // nobody decided before expires_at
+)
+
+// ActionApproval is a dangerous operation held back until a second
+// administrator approves it (four-eyes). Payload carries whatever the
+// operation needs to run once approved.
+type ActionApproval struct {
+	ID            int64                  `json:"id"`
+	Action        string                 `json:"action"`
+	TenantID      string                 `json:"tenant_id,omitempty"`
+	TargetType    string                 `json:"target_type"`
+	TargetID      string                 `json:"target_id"`
+	TargetName    string                 `json:"target_name,omitempty"`
+	Summary       string                 `json:"summary"`
+	Reason        string                 `json:"reason,omitempty"`
+	Payload       map[string]interface{} `json:"payload,omitempty"`
+	Status        string                 `json:"status"`
+	RequestedByID int64                  `json:"requested_by_id"`
+	RequestedBy   string                 `json:"requested_by"`
+	DecidedBy     string                 `json:"decided_by,omitempty"`
+	DecisionNote  string                 `json:"decision_note,omitempty"`
+	Result        string                 `json:"result,omitempty"`
+	CreatedAt     time.Time              `json:"created_at"`
+	ExpiresAt     time.Time              `json:"expires_at"`
+	DecidedAt     *time.Time             `json:"decided_at,omitempty"`
+	ExecutedAt    *time.Time             `json:"executed_at,omitempty"`
+}
+
+// Validate checks a new approval request before it is stored.
+func (a *ActionApproval) Validate() error {
+	a.Action = strings.TrimSpace(a.Action)
+	a.TargetID = strings.TrimSpace(a.TargetID)
+	if a.Action == "" {
+		return fmt.Errorf("action is required")
+	}
+	if a.TargetType == "" || a.TargetID == "" {
+		return fmt.Errorf("target is required")
+	}
+	if a.RequestedByID == 0 {
+		return fmt.Errorf("requested_by_id is required")
+	}
+	if a.ExpiresAt.IsZero() {
+		return fmt.Errorf("expires_at is required")
+	}
+	return nil
+}
+
+// Open reports whether the request can still be approved at now.
+func (a *ActionApproval) Open(now time.Time) bool {
+	return a.Status == ActionApprovalPending && now.Before(a.ExpiresAt)
+}
+
+// ActionApprovalFilter narrows ListActionApprovals. Empty fields match
+// everything.
+type ActionApprovalFilter struct {
+	TenantIDs []string
+	Status    string
+	Action    string
+	TargetID  string
+	Limit     int
+}
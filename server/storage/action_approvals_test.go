@@ -0,0 +1,71 @@
+package storage
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestActionApprovals(t *testing.T) {
+	t.Parallel()
+	s, err := NewSQLiteStore(":memory:")
+	if err != nil {
+		t.Fatalf("NewSQLiteStore: %v", err)
+	}
+	defer s.Close()
+	ctx := context.Background()
+	now := time.Now().UTC()
+
+	if err := s.CreateActionApproval(ctx, &ActionApproval{Action: "agent.delete", ExpiresAt: now.Add(time.Hour)}); err == nil {
+		t.Fatal("expected approval without target to be rejected")
+	}
+	open := &ActionApproval{Action: "agent.delete", TenantID: "t1", TargetType: "agent", TargetID: "a1",
+		Summary: "Delete agent a1", Payload: map[string]interface{}{"agent_id": "a1"},
+		RequestedByID: 7, RequestedBy: "alice", ExpiresAt: now.Add(time.Hour)}
+	if err := s.CreateActionApproval(ctx, open); err != nil {
+		t.Fatalf("CreateActionApproval: %v", err)
+	}
+	stale := &ActionApproval{Action: "agent.force_update", TenantID: "t2", TargetType: "agent", TargetID: "a2",
+		Summary: "Reinstall a2", RequestedByID: 7, RequestedBy: "alice", ExpiresAt: now.Add(-time.Minute)}
+	if err := s.CreateActionApproval(ctx, stale); err != nil {
+		t.Fatalf("CreateActionApproval: %v", err)
+	}
+
+	got, err := s.GetActionApproval(ctx, open.ID)
+	if err != nil || got == nil || got.Status != ActionApprovalPending || got.Payload["agent_id"] != "a1" || !got.Open(now) {
+		t.Fatalf("GetActionApproval: %+v, %v", got, err)
+	}
+
+	list, err := s.ListActionApprovals(ctx, ActionApprovalFilter{TenantIDs: []string{"t1"}, Status: ActionApprovalPending})
+	if err != nil || len(list) != 1 || list[0].ID != open.ID {
+		t.Fatalf("ListActionApprovals(t1): %+v, %v", list, err)
+	}
+
+	// Expired requests cannot be decided and are swept to expired
+	if ok, err := s.DecideActionApproval(ctx, stale.ID, ActionApprovalRejected, "bob", ""); err != nil || ok {
+		t.Fatalf("DecideActionApproval on expired request: %v, %v", ok, err)
+	}
+	if n, err := s.ExpireActionApprovals(ctx, now); err != nil || n != 1 {
+		t.Fatalf("ExpireActionApprovals: %d, %v", n, err)
+	}
+
+	// Only the first decision wins
+	if ok, err := s.DecideActionApproval(ctx, open.ID, ActionApprovalApproved, "bob", "ok"); err != nil || !ok {
+		t.Fatalf("DecideActionApproval: %v, %v", ok, err)
+	}
+	if ok, _ := s.DecideActionApproval(ctx, open.ID, ActionApprovalRejected, "carol", ""); ok {
+		t.Fatal("expected second decision to be refused")
+	}
+	if err := s.CompleteActionApproval(ctx, open.ID, ActionApprovalFailed, "agent not connected"); err != nil {
+		t.Fatalf("CompleteActionApproval: %v", err)
+	}
+	got, _ = s.GetActionApproval(ctx, open.ID)
+	if got.Status != ActionApprovalFailed || got.DecidedBy != "bob" || got.Result != "agent not connected" || got.DecidedAt == nil || got.ExecutedAt == nil {
+		t.Fatalf("unexpected completed approval: %+v", got)
+	}
+
+	all, _ := s.ListActionApprovals(ctx, ActionApprovalFilter{})
+	if len(all) != 2 || all[0].ID != stale.ID || all[0].Status != ActionApprovalExpired {
+		t.Fatalf("unexpected approvals: %+v", all)
+	}
+}
@@ -0,0 +1,114 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestAgentTokenRotationAndGrace(t *testing.T) {
+	t.Parallel()
+	s, err := NewSQLiteStore(":memory:")
+	if err != nil {
+		t.Fatalf("NewSQLiteStore: %v", err)
+	}
+	defer s.Close()
+	ctx := context.Background()
+
+	agent := &Agent{
+		AgentID: "agent-rot", Hostname: "host", IP: "10.0.0.1", Platform: "linux",
+		Version: "1.0.0", ProtocolVersion: "1", Token: "token-old", Status: "active",
+		RegisteredAt: time.Now(), LastSeen: time.Now(),
+	}
+	if err := s.RegisterAgent(ctx, agent); err != nil {
+		t.Fatalf("RegisterAgent: %v", err)
+	}
+	got, err := s.GetAgentByToken(ctx, "token-old")
+	if err != nil {
+		t.Fatalf("GetAgentByToken: %v", err)
+	}
+	if !got.TokenExpiresAt.IsZero() || got.TokenIssuedAt.IsZero() {
+		t.Fatalf("registered token should be issued without expiry: %+v", got)
+	}
+
+	expires := time.Now().UTC().Add(24 * time.Hour)
+	if err := s.RotateAgentToken(ctx, "agent-rot", "token-new", "", expires, time.Now().UTC().Add(time.Minute)); err != nil {
+		t.Fatalf("RotateAgentToken: %v", err)
+	}
+	current, err := s.GetAgentByToken(ctx, "token-new")
+	if err != nil || current.Token != "token-new" || current.TokenExpiresAt.IsZero() {
+		t.Fatalf("new token lookup: %+v %v", current, err)
+	}
+	previous, err := s.GetAgentByToken(ctx, "token-old")
+	if err != nil {
+		t.Fatalf("previous token should work during grace: %v", err)
+	}
+	if previous.Token != "token-new" {
+		t.Fatalf("lookup by previous token should return the current token, got %q", previous.Token)
+	}
+
+	// A second rotation with the grace already over retires token-new at once.
+	if err := s.RotateAgentToken(ctx, "agent-rot", "token-third", "", expires, time.Now().UTC().Add(-time.Second)); err != nil {
+		t.Fatalf("RotateAgentToken: %v", err)
+	}
+	if _, err := s.GetAgentByToken(ctx, "token-new"); err == nil {
+		t.Fatalf("replaced token should be rejected after grace")
+	}
+	if _, err := s.GetAgentByToken(ctx, "token-old"); err == nil {
+		t.Fatalf("token from two rotations ago should be rejected")
+	}
+
+	// Expired current token
+	if err := s.RotateAgentToken(ctx, "agent-rot", "token-expired", "", time.Now().UTC().Add(-time.Minute), time.Time{}); err != nil {
+		t.Fatalf("RotateAgentToken: %v", err)
+	}
+	if _, err := s.GetAgentByToken(ctx, "token-expired"); !errors.Is(err, ErrAgentTokenExpired) {
+		t.Fatalf("expected ErrAgentTokenExpired, got %v", err)
+	}
+}
+
+func TestAgentTokenRotationRequests(t *testing.T) {
+	t.Parallel()
+	s, err := NewSQLiteStore(":memory:")
+	if err != nil {
+		t.Fatalf("NewSQLiteStore: %v", err)
+	}
+	defer s.Close()
+	ctx := context.Background()
+
+	for _, a := range []struct{ id, tenant string }{{"a1", "t1"}, {"a2", "t1"}, {"a3", "t2"}} {
+		if err := s.RegisterAgent(ctx, &Agent{
+			AgentID: a.id, Hostname: a.id, IP: "10.0.0.1", Platform: "linux", Version: "1",
+			ProtocolVersion: "1", Token: "tok-" + a.id, Status: "active", TenantID: a.tenant,
+			RegisteredAt: time.Now(), LastSeen: time.Now(),
+		}); err != nil {
+			t.Fatalf("RegisterAgent %s: %v", a.id, err)
+		}
+	}
+
+	if err := s.RequestAgentTokenRotation(ctx, "a3"); err != nil {
+		t.Fatalf("RequestAgentTokenRotation: %v", err)
+	}
+	if err := s.RequestAgentTokenRotation(ctx, "missing"); err == nil {
+		t.Fatalf("expected error for unknown agent")
+	}
+	count, err := s.RequestFleetTokenRotation(ctx, []string{"t1"})
+	if err != nil || count != 2 {
+		t.Fatalf("RequestFleetTokenRotation: %d %v", count, err)
+	}
+	for _, id := range []string{"a1", "a2", "a3"} {
+		agent, err := s.GetAgent(ctx, id)
+		if err != nil || !agent.TokenRotationRequested {
+			t.Fatalf("agent %s should be flagged: %+v %v", id, agent, err)
+		}
+	}
+
+	if err := s.RotateAgentToken(ctx, "a1", "tok-a1-new", "", time.Now().Add(time.Hour), time.Now().Add(time.Minute)); err != nil {
+		t.Fatalf("RotateAgentToken: %v", err)
+	}
+	agent, _ := s.GetAgent(ctx, "a1")
+	if agent.TokenRotationRequested {
+		t.Fatalf("rotation should clear the request")
+	}
+}
@@ -0,0 +1,82 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"printmaster/common/updatepolicy"
+)
+
+func TestAgentUpdateRunLifecycle(t *testing.T) {
+	t.Parallel()
+	s, err := NewSQLiteStore(":memory:")
+	if err != nil {
+		t.Fatalf("NewSQLiteStore: %v", err)
+	}
+	defer s.Close()
+	ctx := context.Background()
+	start := time.Date(2026, 10, 1, 3, 0, 0, 0, time.UTC)
+
+	record := func(status, code string, diag *updatepolicy.FailureDiagnostics, at time.Time) {
+		t.Helper()
+		run := &AgentUpdateRun{
+			AgentID:        "agent-1",
+			TenantID:       "t1",
+			RunID:          "run-1",
+			Status:         status,
+			CurrentVersion: "1.0.0",
+			TargetVersion:  "1.1.0",
+			ErrorCode:      code,
+			Diagnostics:    diag,
+			StartedAt:      at,
+			UpdatedAt:      at,
+		}
+		if code != "" {
+			run.ErrorMessage = "apply failed"
+			run.ErrorSignature = code + ": apply failed"
+		}
+		if err := s.RecordAgentUpdateRun(ctx, run); err != nil {
+			t.Fatalf("RecordAgentUpdateRun(%s): %v", status, err)
+		}
+	}
+
+	record("downloading", "", nil, start)
+	record("failed", "APPLY_FAILED", &updatepolicy.FailureDiagnostics{OS: "windows", LogTail: []string{"line"}}, start.Add(time.Minute))
+	// A progress report arriving late must not reopen the failed run
+	record("applying", "", nil, start.Add(2*time.Minute))
+
+	runs, err := s.ListAgentUpdateRuns(ctx, AgentUpdateRunFilter{TenantIDs: []string{"t1"}})
+	if err != nil {
+		t.Fatalf("ListAgentUpdateRuns: %v", err)
+	}
+	if len(runs) != 1 {
+		t.Fatalf("len(runs) = %d, want 1", len(runs))
+	}
+	run := runs[0]
+	if run.Status != AgentUpdateStatusFailed || run.ErrorCode != "APPLY_FAILED" || run.ErrorSignature == "" {
+		t.Fatalf("unexpected run: %+v", run)
+	}
+	if run.Diagnostics == nil || run.Diagnostics.OS != "windows" || len(run.Diagnostics.LogTail) != 1 {
+		t.Fatalf("diagnostics not kept: %+v", run.Diagnostics)
+	}
+	if !run.StartedAt.Equal(start) {
+		t.Errorf("StartedAt = %v, want %v", run.StartedAt, start)
+	}
+
+	got, err := s.GetAgentUpdateRun(ctx, run.ID)
+	if err != nil || got.RunID != "run-1" {
+		t.Fatalf("GetAgentUpdateRun = %+v, %v", got, err)
+	}
+	if _, err := s.GetAgentUpdateRun(ctx, run.ID+100); !errors.Is(err, ErrAgentUpdateRunNotFound) {
+		t.Fatalf("GetAgentUpdateRun(missing) err = %v", err)
+	}
+
+	if runs, _ := s.ListAgentUpdateRuns(ctx, AgentUpdateRunFilter{Status: AgentUpdateStatusSucceeded}); len(runs) != 0 {
+		t.Errorf("status filter returned %d runs", len(runs))
+	}
+	if runs, _ := s.ListAgentUpdateRuns(ctx, AgentUpdateRunFilter{TenantIDs: []string{"t2"}}); len(runs) != 0 {
+		t.Errorf("tenant filter returned %d runs", len(runs))
+	}
+}
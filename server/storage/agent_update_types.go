@@ -0,0 +1,48 @@
+package storage
+
+import (
+	"errors"
+	"time"
+)
+
+// Agent update run statuses the server distinguishes. Other values reported by
+// agents (downloading, staging, ...) are stored as-is.
+const (
+	AgentUpdateStatusSucceeded = "succeeded"
+	AgentUpdateStatusFailed    = "failed"
+	AgentUpdateStatusCancelled = "cancelled"
+)
+
+// ErrAgentUpdateRunNotFound is returned when a rollout record does not exist.
+var ErrAgentUpdateRunNotFound = errors.New("agent update run not found")
+
+// AgentUpdateRun is the server's rollout record for one agent self-update
+// attempt, built from the telemetry the agent reports while updating. Once a
+// run has reached a terminal status, late progress reports no longer change
+// it.
+type AgentUpdateRun struct {
+	ID             int64               `json:"id"`
+	AgentID        string              `json:"agent_id"`
+	TenantID       string              `json:"tenant_id,omitempty"`
+	RunID          string              `json:"run_id"`
+	Status         string              `json:"status"`
+	CurrentVersion string              `json:"current_version"`
+	TargetVersion  string              `json:"target_version,omitempty"`
+	ErrorCode      string              `json:"error_code,omitempty"`
+	ErrorMessage   string              `json:"error_message,omitempty"`
+	ErrorSignature string              `json:"error_signature,omitempty"` // Normalized error used to cluster failures
+	Diagnostics    *FailureDiagnostics `json:"diagnostics,omitempty"`
+	Metadata       map[string]any      `json:"metadata,omitempty"`
+	StartedAt      time.Time           `json:"started_at"`
+	UpdatedAt      time.Time           `json:"updated_at"`
+}
+
+// AgentUpdateRunFilter narrows ListAgentUpdateRuns results.
+type AgentUpdateRunFilter struct {
+	AgentID       string
+	TenantIDs     []string // Empty = all tenants
+	Status        string
+	TargetVersion string
+	Since         time.Time // Only runs updated at or after this instant (zero = no bound)
+	Limit         int
+}
@@ -34,7 +34,7 @@ func TestClassifyStatusMessages(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			hasError, hasWarning, hasJam := classifyStatusMessages(tt.messages)
+			hasError, hasWarning, hasJam := classifyStatusMessages("", tt.messages)
 			if hasError != tt.wantError {
 				t.Errorf("hasError = %v, want %v", hasError, tt.wantError)
 			}
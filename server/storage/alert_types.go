@@ -63,6 +63,7 @@ const (
 	AlertTypeUsageHigh         AlertType = "usage_high"
 	AlertTypeSiteOutage        AlertType = "site_outage"
 	AlertTypeFleetMassOutage   AlertType = "fleet_mass_outage"
+	AlertTypeIncident          AlertType = "incident"
 	AlertTypeCustom            AlertType = "custom"
 )
 
@@ -125,6 +126,7 @@ type AlertFilters struct {
 	TenantID  string     `json:"tenant_id,omitempty"`
 	SiteID    string     `json:"site_id,omitempty"`
 	AgentID   string     `json:"agent_id,omitempty"`
+	TopLevel  bool       `json:"top_level,omitempty"` // Exclude alerts grouped under an incident
 	StartTime *time.Time `json:"start_time,omitempty"`
 	EndTime   *time.Time `json:"end_time,omitempty"`
 	Since     *time.Time `json:"since,omitempty"` // Alias for StartTime
@@ -275,7 +277,71 @@ type AlertSettings struct {
 	GroupingEnabled       bool       `json:"grouping_enabled"`
 	GroupingThreshold     int        `json:"grouping_threshold"`
 	DependenciesEnabled   bool       `json:"dependencies_enabled"`
-	UpdatedAt             time.Time  `json:"updated_at"`
+
+	// CorrelationRules group related alerts into incidents while
+	// GroupingEnabled is set. Empty means DefaultCorrelationRules.
+	CorrelationRules []AlertCorrelationRule `json:"correlation_rules,omitempty"`
+
+	// Flood suppression: once FloodThreshold new top-level alerts have been
+	// raised within FloodWindowMins, further alerts are folded into a single
+	// fleet-wide incident instead of being raised individually.
+	FloodSuppressionEnabled bool `json:"flood_suppression_enabled"`
+	FloodThreshold          int  `json:"flood_threshold"`
+	FloodWindowMins         int  `json:"flood_window_mins"`
+
+	// Incident promotion: a device offline for IncidentOfflineMins, or with
+	// IncidentJamCount paper_jam alerts within IncidentJamWindowHours, gets an
+	// incident. Zero values use the defaults (60 minutes, 3 jams in 24 hours).
+	IncidentsEnabled       bool `json:"incidents_enabled"`
+	IncidentOfflineMins    int  `json:"incident_offline_mins,omitempty"`
+	IncidentJamCount       int  `json:"incident_jam_count,omitempty"`
+	IncidentJamWindowHours int  `json:"incident_jam_window_hours,omitempty"`
+
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// Correlation grouping keys
+const (
+	CorrelationGroupByAgent  = "agent"
+	CorrelationGroupBySubnet = "subnet"
+	CorrelationGroupByTenant = "tenant"
+)
+
+// AlertCorrelationRule groups alerts that share an agent, subnet or tenant
+// and trigger within a window into a single incident alert. The grouped
+// alerts become children of the incident (ParentAlertID).
+type AlertCorrelationRule struct {
+	Name          string   `json:"name"`
+	Enabled       bool     `json:"enabled"`
+	GroupBy       string   `json:"group_by"`
+	AlertTypes    []string `json:"alert_types,omitempty"` // empty matches every type
+	WindowMinutes int      `json:"window_minutes"`
+	MinAlerts     int      `json:"min_alerts"`
+	SubnetBits    int      `json:"subnet_bits,omitempty"` // IPv4 prefix length for group_by=subnet (default 24)
+}
+
+// DefaultCorrelationRules groups offline devices first by subnet (a dead
+// switch) and then by agent (a site losing its network).
+func DefaultCorrelationRules() []AlertCorrelationRule {
+	return []AlertCorrelationRule{
+		{
+			Name:          "Subnet outage",
+			Enabled:       true,
+			GroupBy:       CorrelationGroupBySubnet,
+			AlertTypes:    []string{AlertTypeDeviceOffline},
+			WindowMinutes: 15,
+			MinAlerts:     5,
+			SubnetBits:    24,
+		},
+		{
+			Name:          "Agent outage",
+			Enabled:       true,
+			GroupBy:       CorrelationGroupByAgent,
+			AlertTypes:    []string{AlertTypeDeviceOffline},
+			WindowMinutes: 15,
+			MinAlerts:     5,
+		},
+	}
 }
 
 // AlertCountsByScope holds counts broken down by scope level
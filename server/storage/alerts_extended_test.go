@@ -406,3 +406,57 @@ func TestListAlertHistory(t *testing.T) {
 		t.Errorf("expected 1 history alert with limit, got %d", len(historyWithLimit))
 	}
 }
+
+func TestUnsnoozeExpiredAlerts(t *testing.T) {
+	t.Parallel()
+	s, err := NewSQLiteStore(":memory:")
+	if err != nil {
+		t.Fatalf("NewSQLiteStore: %v", err)
+	}
+	defer s.Close()
+
+	ctx := context.Background()
+	newAlert := func(serial string) int64 {
+		id, err := s.CreateAlert(ctx, &Alert{
+			Type: AlertTypeSupplyLow, Severity: AlertSeverityWarning, Scope: AlertScopeDevice,
+			Status: AlertStatusActive, DeviceSerial: serial, Title: "Low Toner", TriggeredAt: time.Now().UTC(),
+		})
+		if err != nil {
+			t.Fatalf("CreateAlert: %v", err)
+		}
+		return id
+	}
+	expired, snoozed, resolved := newAlert("DEV001"), newAlert("DEV002"), newAlert("DEV003")
+
+	now := time.Now().UTC()
+	if err := s.SuppressAlert(ctx, expired, now.Add(-time.Minute)); err != nil {
+		t.Fatalf("SuppressAlert: %v", err)
+	}
+	if err := s.SuppressAlert(ctx, snoozed, now.Add(time.Hour)); err != nil {
+		t.Fatalf("SuppressAlert: %v", err)
+	}
+	if err := s.ResolveAlert(ctx, resolved); err != nil {
+		t.Fatalf("ResolveAlert: %v", err)
+	}
+	// Snoozing a resolved alert must not reopen it
+	if err := s.SuppressAlert(ctx, resolved, now.Add(time.Hour)); err != nil {
+		t.Fatalf("SuppressAlert: %v", err)
+	}
+
+	woken, err := s.UnsnoozeExpiredAlerts(ctx, now)
+	if err != nil {
+		t.Fatalf("UnsnoozeExpiredAlerts: %v", err)
+	}
+	if woken != 1 {
+		t.Fatalf("expected 1 woken alert, got %d", woken)
+	}
+	for id, want := range map[int64]AlertStatus{expired: AlertStatusActive, snoozed: AlertStatusSuppressed, resolved: AlertStatusResolved} {
+		got, err := s.GetAlert(ctx, id)
+		if err != nil {
+			t.Fatalf("GetAlert: %v", err)
+		}
+		if got.Status != want {
+			t.Errorf("alert %d: expected status %q, got %q", id, want, got.Status)
+		}
+	}
+}
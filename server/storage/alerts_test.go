@@ -175,6 +175,63 @@ func TestAlertFilters(t *testing.T) {
 	}
 }
 
+func TestAlertIncidentChildren(t *testing.T) {
+	t.Parallel()
+	s, err := NewSQLiteStore(":memory:")
+	if err != nil {
+		t.Fatalf("NewSQLiteStore: %v", err)
+	}
+	defer s.Close()
+
+	ctx := context.Background()
+	now := time.Now().UTC()
+
+	incidentID, err := s.CreateAlert(ctx, &Alert{
+		Type: AlertTypeIncident, Severity: AlertSeverityWarning, Scope: AlertScopeAgent,
+		Status: AlertStatusActive, AgentID: "agent-1", Title: "Agent outage: agent-1", TriggeredAt: now,
+	})
+	if err != nil {
+		t.Fatalf("CreateAlert incident: %v", err)
+	}
+
+	var childIDs []int64
+	for _, serial := range []string{"SN1", "SN2", "SN3"} {
+		id, err := s.CreateAlert(ctx, &Alert{
+			Type: AlertTypeDeviceOffline, Severity: AlertSeverityWarning, Scope: AlertScopeDevice,
+			Status: AlertStatusActive, AgentID: "agent-1", DeviceSerial: serial, Title: "Device Offline", TriggeredAt: now,
+		})
+		if err != nil {
+			t.Fatalf("CreateAlert child: %v", err)
+		}
+		childIDs = append(childIDs, id)
+	}
+
+	if err := s.SetAlertParent(ctx, incidentID, childIDs[:2]); err != nil {
+		t.Fatalf("SetAlertParent: %v", err)
+	}
+
+	children, err := s.ListChildAlerts(ctx, incidentID)
+	if err != nil {
+		t.Fatalf("ListChildAlerts: %v", err)
+	}
+	if len(children) != 2 {
+		t.Fatalf("expected 2 children, got %d", len(children))
+	}
+	for _, c := range children {
+		if c.ParentAlertID == nil || *c.ParentAlertID != incidentID {
+			t.Errorf("child %d has parent %v, want %d", c.ID, c.ParentAlertID, incidentID)
+		}
+	}
+
+	incident, err := s.GetAlert(ctx, incidentID)
+	if err != nil {
+		t.Fatalf("GetAlert: %v", err)
+	}
+	if incident.ChildCount != 2 {
+		t.Errorf("expected child_count 2, got %d", incident.ChildCount)
+	}
+}
+
 func TestAlertRuleLifecycle(t *testing.T) {
 	t.Parallel()
 	s, err := NewSQLiteStore(":memory:")
@@ -0,0 +1,74 @@
+package storage
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestAPITokenUsage(t *testing.T) {
+	t.Parallel()
+	s, err := NewSQLiteStore(":memory:")
+	if err != nil {
+		t.Fatalf("NewSQLiteStore: %v", err)
+	}
+	defer s.Close()
+	ctx := context.Background()
+
+	user := &User{Username: "integrator", Role: RoleOperator, TenantID: "t1"}
+	if err := s.CreateUser(ctx, user, "Secret-pass-1"); err != nil {
+		t.Fatalf("CreateUser: %v", err)
+	}
+	tok, err := s.CreateAPIToken(ctx, user.ID, " ERP sync ", 10*24*60)
+	if err != nil {
+		t.Fatalf("CreateAPIToken: %v", err)
+	}
+	ses, err := s.GetSessionByToken(ctx, tok.Token)
+	if err != nil {
+		t.Fatalf("GetSessionByToken: %v", err)
+	}
+	if ses.Kind != SessionKindAPI || ses.Name != "ERP sync" || ses.Username != "integrator" {
+		t.Fatalf("unexpected API token session: %+v", ses)
+	}
+	web, err := s.CreateSession(ctx, user.ID, 60)
+	if err != nil {
+		t.Fatalf("CreateSession: %v", err)
+	}
+	if ses, _ := s.GetSessionByToken(ctx, web.Token); ses.Kind != SessionKindWeb {
+		t.Fatalf("web session kind = %q", ses.Kind)
+	}
+
+	expiring, err := s.ListAPITokensExpiring(ctx, time.Now().Add(14*24*time.Hour))
+	if err != nil || len(expiring) != 1 || expiring[0].Token != ses.Token {
+		t.Fatalf("ListAPITokensExpiring = %+v, %v", expiring, err)
+	}
+	if err := s.MarkAPITokenExpiryWarned(ctx, ses.Token, time.Now()); err != nil {
+		t.Fatalf("MarkAPITokenExpiryWarned: %v", err)
+	}
+	if expiring, _ := s.ListAPITokensExpiring(ctx, time.Now().Add(14*24*time.Hour)); len(expiring) != 0 {
+		t.Fatalf("warned token listed again: %+v", expiring)
+	}
+
+	now := time.Now().UTC().Truncate(time.Second)
+	today := APITokenUsageDay(now)
+	batch := []APITokenUsage{
+		{TokenHash: ses.Token, Day: today, Endpoint: "GET /api/v1/devices", Requests: 3, Errors: 1, LastUsedAt: now.Add(-time.Minute)},
+		{TokenHash: ses.Token, Day: "2020-01-01", Endpoint: "GET /api/v1/devices", Requests: 9, LastUsedAt: now.AddDate(-6, 0, 0)},
+	}
+	if err := s.RecordAPITokenUsage(ctx, batch); err != nil {
+		t.Fatalf("RecordAPITokenUsage: %v", err)
+	}
+	if err := s.RecordAPITokenUsage(ctx, []APITokenUsage{{TokenHash: ses.Token, Day: today, Endpoint: "GET /api/v1/devices", Requests: 2, LastUsedAt: now}}); err != nil {
+		t.Fatalf("RecordAPITokenUsage (merge): %v", err)
+	}
+	usage, err := s.ListAPITokenUsage(ctx, ses.Token, now.AddDate(0, 0, -30))
+	if err != nil || len(usage) != 1 {
+		t.Fatalf("ListAPITokenUsage = %+v, %v", usage, err)
+	}
+	if u := usage[0]; u.Requests != 5 || u.Errors != 1 || !u.LastUsedAt.Equal(now) {
+		t.Fatalf("merged usage = %+v", u)
+	}
+	if n, err := s.DeleteAPITokenUsageBefore(ctx, now.AddDate(0, 0, -90)); err != nil || n != 1 {
+		t.Fatalf("DeleteAPITokenUsageBefore = %d, %v", n, err)
+	}
+}
@@ -0,0 +1,174 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// ============================================================
+// Action Approval Storage Methods (BaseStore)
+// ============================================================
+
+const actionApprovalColumns = `id, action, tenant_id, target_type, target_id, target_name,
+	summary, reason, payload_json, status, requested_by_id, requested_by,
+	decided_by, decision_note, result, created_at, expires_at, decided_at, executed_at`
+
+// CreateActionApproval stores a new pending approval request.
+func (s *BaseStore) CreateActionApproval(ctx context.Context, a *ActionApproval) error {
+	if err := a.Validate(); err != nil {
+		return err
+	}
+	payload, err := json.Marshal(a.Payload)
+	if err != nil {
+		return err
+	}
+	now := time.Now().UTC()
+	id, err := s.insertReturningID(ctx, `
+		INSERT INTO action_approvals (
+			action, tenant_id, target_type, target_id, target_name, summary, reason,
+			payload_json, status, requested_by_id, requested_by, created_at, expires_at
+		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`,
+		a.Action, nullString(a.TenantID), a.TargetType, a.TargetID, nullString(a.TargetName), a.Summary,
+		nullString(a.Reason), string(payload), ActionApprovalPending, a.RequestedByID, a.RequestedBy,
+		now, a.ExpiresAt.UTC(),
+	)
+	if err != nil {
+		return fmt.Errorf("create action approval: %w", err)
+	}
+	a.ID = id
+	a.Status = ActionApprovalPending
+	a.CreatedAt = now
+	return nil
+}
+
+// GetActionApproval returns an approval request by ID, or nil if it doesn't
+// exist.
+func (s *BaseStore) GetActionApproval(ctx context.Context, id int64) (*ActionApproval, error) {
+	row := s.queryRowContext(ctx, `SELECT `+actionApprovalColumns+` FROM action_approvals WHERE id = ?`, id)
+	a, err := scanActionApproval(row)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return a, err
+}
+
+// ListActionApprovals returns approval requests, newest first.
+func (s *BaseStore) ListActionApprovals(ctx context.Context, filter ActionApprovalFilter) ([]*ActionApproval, error) {
+	query := `SELECT ` + actionApprovalColumns + ` FROM action_approvals WHERE 1=1`
+	var args []interface{}
+	if len(filter.TenantIDs) > 0 {
+		placeholders := make([]string, len(filter.TenantIDs))
+		for i, t := range filter.TenantIDs {
+			placeholders[i] = "?"
+			args = append(args, t)
+		}
+		query += fmt.Sprintf(` AND tenant_id IN (%s)`, strings.Join(placeholders, ","))
+	}
+	if filter.Status != "" {
+		query += ` AND status = ?`
+		args = append(args, filter.Status)
+	}
+	if filter.Action != "" {
+		query += ` AND action = ?`
+		args = append(args, filter.Action)
+	}
+	if filter.TargetID != "" {
+		query += ` AND target_id = ?`
+		args = append(args, filter.TargetID)
+	}
+	query += ` ORDER BY created_at DESC, id DESC`
+	if filter.Limit > 0 {
+		query += fmt.Sprintf(` LIMIT %d`, filter.Limit)
+	}
+	rows, err := s.queryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var approvals []*ActionApproval
+	for rows.Next() {
+		a, err := scanActionApproval(rows)
+		if err != nil {
+			return nil, err
+		}
+		approvals = append(approvals, a)
+	}
+	return approvals, rows.Err()
+}
+
+// DecideActionApproval moves a pending, unexpired request to status. It
+// reports false when the request was no longer open, so two approvers racing
+// on the same request cannot both act on it.
+func (s *BaseStore) DecideActionApproval(ctx context.Context, id int64, status, decidedBy, note string) (bool, error) {
+	now := time.Now().UTC()
+	res, err := s.execContext(ctx, `
+		UPDATE action_approvals SET status = ?, decided_by = ?, decision_note = ?, decided_at = ?
+		WHERE id = ? AND status = ? AND expires_at > ?
+	`, status, nullString(decidedBy), nullString(note), now, id, ActionApprovalPending, now)
+	if err != nil {
+		return false, err
+	}
+	n, _ := res.RowsAffected()
+	return n > 0, nil
+}
+
+// CompleteActionApproval records the outcome of running an approved action.
+func (s *BaseStore) CompleteActionApproval(ctx context.Context, id int64, status, result string) error {
+	_, err := s.execContext(ctx, `
+		UPDATE action_approvals SET status = ?, result = ?, executed_at = ?
+		WHERE id = ?
+	`, status, nullString(result), time.Now().UTC(), id)
+	return err
+}
+
+// ExpireActionApprovals marks pending requests past their expiry as expired
+// and returns how many were changed.
+func (s *BaseStore) ExpireActionApprovals(ctx context.Context, now time.Time) (int64, error) {
+	res, err := s.execContext(ctx, `
+		UPDATE action_approvals SET status = ?
+		WHERE status = ? AND expires_at <= ?
+	`, ActionApprovalExpired, ActionApprovalPending, now.UTC())
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+func scanActionApproval(row interface{ Scan(...interface{}) error }) (*ActionApproval, error) {
+	var a ActionApproval
+	var tenantID, targetName, reason, payload, decidedBy, note, result sql.NullString
+	var decidedAt, executedAt sql.NullTime
+	if err := row.Scan(
+		&a.ID, &a.Action, &tenantID, &a.TargetType, &a.TargetID, &targetName,
+		&a.Summary, &reason, &payload, &a.Status, &a.RequestedByID, &a.RequestedBy,
+		&decidedBy, &note, &result, &a.CreatedAt, &a.ExpiresAt, &decidedAt, &executedAt,
+	); err != nil {
+		return nil, err
+	}
+	a.TenantID = tenantID.String
+	a.TargetName = targetName.String
+	a.Reason = reason.String
+	if payload.Valid && payload.String != "" && payload.String != "null" {
+		if err := json.Unmarshal([]byte(payload.String), &a.Payload); err != nil {
+			return nil, fmt.Errorf("decode action approval payload: %w", err)
+		}
+	}
+	a.DecidedBy = decidedBy.String
+	a.DecisionNote = note.String
+	a.Result = result.String
+	if decidedAt.Valid {
+		t := decidedAt.Time
+		a.DecidedAt = &t
+	}
+	if executedAt.Valid {
+		t := executedAt.Time
+		a.ExecutedAt = &t
+	}
+	return &a, nil
+}
@@ -0,0 +1,87 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// RotateAgentToken replaces an agent's bearer token. The old token keeps
+// working until graceUntil so requests already in flight, or an agent that
+// has not yet persisted the new token, are not locked out; a zero graceUntil
+// revokes it at once. resendNonce (a hash of the nonce the agent sent with the
+// rotating heartbeat, or "") is what a later heartbeat must match to be sent
+// the new token again. Any pending forced rotation is cleared.
+func (s *BaseStore) RotateAgentToken(ctx context.Context, agentID, newToken, resendNonce string, expiresAt, graceUntil time.Time) error {
+	if strings.TrimSpace(agentID) == "" || strings.TrimSpace(newToken) == "" {
+		return fmt.Errorf("agent id and token required")
+	}
+	res, err := s.execContext(ctx, `
+		UPDATE agents SET
+			previous_token = token,
+			previous_token_expires_at = ?,
+			token = ?,
+			token_issued_at = ?,
+			token_expires_at = ?,
+			token_rotation_requested = ?,
+			token_resend_nonce = ?
+		WHERE agent_id = ?
+	`, nullTime(graceUntil), newToken, time.Now().UTC(), nullTime(expiresAt), boolToInt(false), nullString(resendNonce), agentID)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return fmt.Errorf("agent not found")
+	}
+	return nil
+}
+
+// GetAgentTokenResendNonce returns the resend nonce hash stored by the last
+// rotation, or "" when the new token must not be sent again.
+func (s *BaseStore) GetAgentTokenResendNonce(ctx context.Context, agentID string) (string, error) {
+	var nonce sql.NullString
+	err := s.queryRowContext(ctx, `SELECT token_resend_nonce FROM agents WHERE agent_id = ?`, agentID).Scan(&nonce)
+	if err == sql.ErrNoRows {
+		return "", fmt.Errorf("agent not found")
+	}
+	if err != nil {
+		return "", err
+	}
+	return nonce.String, nil
+}
+
+// RequestAgentTokenRotation flags one agent so its next heartbeat receives a
+// new token.
+func (s *BaseStore) RequestAgentTokenRotation(ctx context.Context, agentID string) error {
+	res, err := s.execContext(ctx, `UPDATE agents SET token_rotation_requested = ? WHERE agent_id = ?`,
+		boolToInt(true), agentID)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return fmt.Errorf("agent not found")
+	}
+	return nil
+}
+
+// RequestFleetTokenRotation flags every agent (optionally limited to the
+// given tenants) for token rotation and returns how many were flagged.
+func (s *BaseStore) RequestFleetTokenRotation(ctx context.Context, tenantIDs []string) (int64, error) {
+	query := `UPDATE agents SET token_rotation_requested = ?`
+	args := []interface{}{boolToInt(true)}
+	if len(tenantIDs) > 0 {
+		placeholders := make([]string, len(tenantIDs))
+		for i, t := range tenantIDs {
+			placeholders[i] = "?"
+			args = append(args, t)
+		}
+		query += fmt.Sprintf(` WHERE tenant_id IN (%s)`, strings.Join(placeholders, ","))
+	}
+	res, err := s.execContext(ctx, query, args...)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
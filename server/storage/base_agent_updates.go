@@ -0,0 +1,162 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// ============================================================
+// Agent Update Run Storage Methods (BaseStore)
+// ============================================================
+
+// agentUpdateTakeNew is true when an incoming report may replace the stored
+// status: terminal reports always win (a post-restart validation failure
+// overrides an earlier success), progress reports only while the run is
+// still in flight. Agents send reports concurrently, so they can arrive out
+// of order.
+const agentUpdateTakeNew = `(excluded.status IN ('succeeded', 'failed', 'cancelled')
+	OR agent_update_runs.status NOT IN ('succeeded', 'failed', 'cancelled'))`
+
+// RecordAgentUpdateRun creates or updates the rollout record for the agent's
+// run from a telemetry report. Diagnostics and metadata are kept from earlier
+// reports when the new one carries none.
+func (s *BaseStore) RecordAgentUpdateRun(ctx context.Context, run *AgentUpdateRun) error {
+	if run == nil || run.AgentID == "" || run.RunID == "" || run.Status == "" {
+		return fmt.Errorf("agent_id, run_id and status are required")
+	}
+	now := time.Now().UTC()
+	if run.StartedAt.IsZero() {
+		run.StartedAt = now
+	}
+	if run.UpdatedAt.IsZero() {
+		run.UpdatedAt = now
+	}
+	var diagnostics sql.NullString
+	if run.Diagnostics != nil {
+		data, err := json.Marshal(run.Diagnostics)
+		if err != nil {
+			return fmt.Errorf("failed to encode update diagnostics: %w", err)
+		}
+		diagnostics = sql.NullString{String: string(data), Valid: true}
+	}
+	metaJSON, err := encodeMetadata(run.Metadata)
+	if err != nil {
+		return err
+	}
+
+	_, err = s.execContext(ctx, `
+		INSERT INTO agent_update_runs (agent_id, tenant_id, run_id, status, current_version, target_version,
+			error_code, error_message, error_signature, diagnostics, metadata_json, started_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT(agent_id, run_id) DO UPDATE SET
+			tenant_id = excluded.tenant_id,
+			status = CASE WHEN `+agentUpdateTakeNew+` THEN excluded.status ELSE agent_update_runs.status END,
+			error_code = CASE WHEN `+agentUpdateTakeNew+` THEN excluded.error_code ELSE agent_update_runs.error_code END,
+			error_message = CASE WHEN `+agentUpdateTakeNew+` THEN excluded.error_message ELSE agent_update_runs.error_message END,
+			error_signature = CASE WHEN `+agentUpdateTakeNew+` THEN excluded.error_signature ELSE agent_update_runs.error_signature END,
+			current_version = excluded.current_version,
+			target_version = COALESCE(excluded.target_version, agent_update_runs.target_version),
+			diagnostics = COALESCE(excluded.diagnostics, agent_update_runs.diagnostics),
+			metadata_json = COALESCE(excluded.metadata_json, agent_update_runs.metadata_json),
+			updated_at = excluded.updated_at
+	`, run.AgentID, nullString(run.TenantID), run.RunID, run.Status, run.CurrentVersion, nullString(run.TargetVersion),
+		nullString(run.ErrorCode), nullString(run.ErrorMessage), nullString(run.ErrorSignature), diagnostics, metaJSON,
+		run.StartedAt, run.UpdatedAt)
+	return err
+}
+
+// GetAgentUpdateRun returns a rollout record, including its diagnostics.
+func (s *BaseStore) GetAgentUpdateRun(ctx context.Context, id int64) (*AgentUpdateRun, error) {
+	rows, err := s.queryContext(ctx, agentUpdateRunSelect+" WHERE id = ?", id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	runs, err := scanAgentUpdateRuns(rows)
+	if err != nil {
+		return nil, err
+	}
+	if len(runs) == 0 {
+		return nil, ErrAgentUpdateRunNotFound
+	}
+	return runs[0], nil
+}
+
+// ListAgentUpdateRuns returns rollout records matching filter, most recently
+// updated first.
+func (s *BaseStore) ListAgentUpdateRuns(ctx context.Context, filter AgentUpdateRunFilter) ([]*AgentUpdateRun, error) {
+	var where []string
+	var args []interface{}
+	if filter.AgentID != "" {
+		where = append(where, "agent_id = ?")
+		args = append(args, filter.AgentID)
+	}
+	if filter.Status != "" {
+		where = append(where, "status = ?")
+		args = append(args, filter.Status)
+	}
+	if filter.TargetVersion != "" {
+		where = append(where, "target_version = ?")
+		args = append(args, filter.TargetVersion)
+	}
+	if !filter.Since.IsZero() {
+		where = append(where, "updated_at >= ?")
+		args = append(args, filter.Since.UTC())
+	}
+	if len(filter.TenantIDs) > 0 {
+		where = append(where, "tenant_id IN ("+placeholderList(len(filter.TenantIDs))+")")
+		for _, id := range filter.TenantIDs {
+			args = append(args, id)
+		}
+	}
+
+	query := agentUpdateRunSelect
+	if len(where) > 0 {
+		query += " WHERE " + strings.Join(where, " AND ")
+	}
+	query += " ORDER BY updated_at DESC, id DESC"
+	if filter.Limit > 0 {
+		query += " LIMIT ?"
+		args = append(args, filter.Limit)
+	}
+
+	rows, err := s.queryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	return scanAgentUpdateRuns(rows)
+}
+
+const agentUpdateRunSelect = `
+		SELECT id, agent_id, tenant_id, run_id, status, current_version, target_version,
+		       error_code, error_message, error_signature, diagnostics, metadata_json, started_at, updated_at
+		FROM agent_update_runs`
+
+func scanAgentUpdateRuns(rows *sql.Rows) ([]*AgentUpdateRun, error) {
+	var runs []*AgentUpdateRun
+	for rows.Next() {
+		var run AgentUpdateRun
+		var tenantID, currentVersion, targetVersion, errCode, errMsg, errSig, diagnostics, metaJSON sql.NullString
+		if err := rows.Scan(&run.ID, &run.AgentID, &tenantID, &run.RunID, &run.Status, &currentVersion, &targetVersion,
+			&errCode, &errMsg, &errSig, &diagnostics, &metaJSON, &run.StartedAt, &run.UpdatedAt); err != nil {
+			return nil, err
+		}
+		run.TenantID = tenantID.String
+		run.CurrentVersion = currentVersion.String
+		run.TargetVersion = targetVersion.String
+		run.ErrorCode = errCode.String
+		run.ErrorMessage = errMsg.String
+		run.ErrorSignature = errSig.String
+		run.Metadata = decodeMetadata(metaJSON)
+		if diagnostics.String != "" {
+			_ = json.Unmarshal([]byte(diagnostics.String), &run.Diagnostics)
+		}
+		runs = append(runs, &run)
+	}
+	return runs, rows.Err()
+}
@@ -222,6 +222,9 @@ func (s *BaseStore) ListActiveAlerts(ctx context.Context, filters AlertFilters)
 		query += " AND tenant_id = ?"
 		args = append(args, filters.TenantID)
 	}
+	if filters.TopLevel {
+		query += " AND parent_alert_id IS NULL"
+	}
 
 	query += " ORDER BY severity DESC, triggered_at DESC"
 
@@ -350,17 +353,84 @@ func (s *BaseStore) ResolveAlert(ctx context.Context, id int64) error {
 	return err
 }
 
-// SuppressAlert suppresses an alert until a given time.
+// SuppressAlert snoozes an open alert until a given time. Resolved alerts
+// are left alone.
 func (s *BaseStore) SuppressAlert(ctx context.Context, id int64, until time.Time) error {
 	now := time.Now().UTC()
 	_, err := s.execContext(ctx, `
 		UPDATE alerts 
 		SET status = 'suppressed', suppressed_until = ?, updated_at = ?
-		WHERE id = ?
-	`, until, now, id)
+		WHERE id = ? AND status IN ('active', 'acknowledged', 'suppressed')
+	`, until.UTC(), now, id)
 	return err
 }
 
+// UnsnoozeExpiredAlerts returns suppressed alerts whose suppressed_until has
+// passed to the active state and reports how many were woken.
+func (s *BaseStore) UnsnoozeExpiredAlerts(ctx context.Context, now time.Time) (int64, error) {
+	res, err := s.execContext(ctx, `
+		UPDATE alerts
+		SET status = 'active', suppressed_until = NULL, updated_at = ?
+		WHERE status = 'suppressed' AND suppressed_until IS NOT NULL AND suppressed_until <= ?
+	`, now.UTC(), now.UTC())
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+// SetAlertParent attaches childIDs to the incident parentID and refreshes
+// the incident's child_count.
+func (s *BaseStore) SetAlertParent(ctx context.Context, parentID int64, childIDs []int64) error {
+	now := time.Now().UTC()
+	if len(childIDs) > 0 {
+		args := []interface{}{parentID, now}
+		for _, id := range childIDs {
+			args = append(args, id)
+		}
+		if _, err := s.execContext(ctx, `
+			UPDATE alerts SET parent_alert_id = ?, updated_at = ?
+			WHERE id IN (`+placeholderList(len(childIDs))+`)
+		`, args...); err != nil {
+			return fmt.Errorf("set alert parent: %w", err)
+		}
+	}
+	if _, err := s.execContext(ctx, `
+		UPDATE alerts
+		SET child_count = (SELECT COUNT(*) FROM alerts WHERE parent_alert_id = ?), updated_at = ?
+		WHERE id = ?
+	`, parentID, now, parentID); err != nil {
+		return fmt.Errorf("update alert child count: %w", err)
+	}
+	return nil
+}
+
+// ListChildAlerts returns the alerts grouped under an incident, newest first.
+func (s *BaseStore) ListChildAlerts(ctx context.Context, parentID int64) ([]Alert, error) {
+	rows, err := s.queryContext(ctx, `
+		SELECT 
+			id, rule_id, type, severity, scope, status,
+			tenant_id, site_id, agent_id, device_serial,
+			title, message, details,
+			triggered_at, acknowledged_at, acknowledged_by, resolved_at,
+			suppressed_until, expires_at,
+			escalation_level, last_escalated_at,
+			state_change_count, is_flapping,
+			parent_alert_id, child_count,
+			notifications_sent, last_notified_at,
+			created_at, updated_at
+		FROM alerts
+		WHERE parent_alert_id = ?
+		ORDER BY triggered_at DESC
+	`, parentID)
+	if err != nil {
+		return nil, fmt.Errorf("list child alerts: %w", err)
+	}
+	defer rows.Close()
+
+	return s.scanAlerts(rows)
+}
+
 // UpdateAlertNotificationStatus updates the notification tracking fields on an alert.
 func (s *BaseStore) UpdateAlertNotificationStatus(ctx context.Context, id int64, sent int, lastNotified time.Time) error {
 	now := time.Now().UTC()
@@ -1231,12 +1301,20 @@ func (s *BaseStore) GetAlertSettings(ctx context.Context) (*AlertSettings, error
 				Timezone:      "local",
 				AllowCritical: true,
 			},
-			FlappingEnabled:     true,
-			FlappingThreshold:   5,
-			FlappingWindowMins:  10,
-			GroupingEnabled:     true,
-			GroupingThreshold:   50,
-			DependenciesEnabled: true,
+			FlappingEnabled:         true,
+			FlappingThreshold:       5,
+			FlappingWindowMins:      10,
+			GroupingEnabled:         true,
+			GroupingThreshold:       50,
+			DependenciesEnabled:     true,
+			CorrelationRules:        DefaultCorrelationRules(),
+			FloodSuppressionEnabled: true,
+			FloodThreshold:          25,
+			FloodWindowMins:         10,
+			IncidentsEnabled:        true,
+			IncidentOfflineMins:     60,
+			IncidentJamCount:        3,
+			IncidentJamWindowHours:  24,
 		}, nil
 	}
 	if err != nil {
@@ -1470,6 +1548,19 @@ func DefaultAlertRules() []AlertRule {
 			UpdatedAt:       now,
 			CreatedBy:       "system",
 		},
+		{
+			Name:            "Paper Jam",
+			Description:     "Alerts when a device reports a paper jam; repeated jams are promoted to an incident",
+			Enabled:         true,
+			Type:            AlertTypePaperJam,
+			Severity:        AlertSeverityWarning,
+			Scope:           AlertScopeDevice,
+			DurationMinutes: 0,
+			CooldownMinutes: 0,
+			CreatedAt:       now,
+			UpdatedAt:       now,
+			CreatedBy:       "system",
+		},
 		{
 			Name:            "High Page Volume",
 			Description:     "Alerts when daily page count exceeds 1000 pages",
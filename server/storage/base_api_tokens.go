@@ -0,0 +1,143 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// APITokenUsage is one day of requests made with an API token to one
+// endpoint ("GET /api/v1/devices/{id}").
+type APITokenUsage struct {
+	TokenHash  string    `json:"-"`
+	Day        string    `json:"day"` // YYYY-MM-DD (UTC)
+	Endpoint   string    `json:"endpoint"`
+	Requests   int64     `json:"requests"`
+	Errors     int64     `json:"errors"` // Responses with status >= 400
+	LastUsedAt time.Time `json:"last_used_at"`
+}
+
+// APITokenUsageDay formats t as the day key used by api_token_usage.
+func APITokenUsageDay(t time.Time) string {
+	return t.UTC().Format("2006-01-02")
+}
+
+// CreateAPIToken creates a named API token (a session of kind api)
+func (s *BaseStore) CreateAPIToken(ctx context.Context, userID int64, name string, ttlMinutes int) (*Session, error) {
+	if ttlMinutes <= 0 {
+		return nil, fmt.Errorf("ttl must be positive")
+	}
+	rawToken, err := generateSecureToken(32)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate API token: %w", err)
+	}
+	name = strings.TrimSpace(name)
+	if len(name) > 128 {
+		name = name[:128]
+	}
+	now := time.Now().UTC()
+	expiresAt := now.Add(time.Duration(ttlMinutes) * time.Minute)
+	_, err = s.execContext(ctx, `INSERT INTO sessions (token, user_id, expires_at, created_at, last_seen_at, kind, name) VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		hashSHA256(rawToken), userID, expiresAt, now, now, SessionKindAPI, nullString(name))
+	if err != nil {
+		return nil, err
+	}
+	return &Session{
+		Token:      rawToken,
+		UserID:     userID,
+		ExpiresAt:  expiresAt,
+		CreatedAt:  now,
+		LastSeenAt: now,
+		Kind:       SessionKindAPI,
+		Name:       name,
+	}, nil
+}
+
+// ListAPITokensExpiring returns unexpired API tokens that expire before the
+// given time and whose owner has not been warned yet
+func (s *BaseStore) ListAPITokensExpiring(ctx context.Context, before time.Time) ([]*Session, error) {
+	rows, err := s.queryContext(ctx, `
+		SELECT `+sessionColumns+`
+		FROM sessions s
+		LEFT JOIN users u ON s.user_id = u.id
+		WHERE s.kind = ? AND s.expiry_warned_at IS NULL AND s.expires_at > ? AND s.expires_at <= ?
+		ORDER BY s.expires_at
+	`, SessionKindAPI, time.Now().UTC(), before.UTC())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []*Session
+	for rows.Next() {
+		sess, err := scanSession(rows)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, sess)
+	}
+	return out, rows.Err()
+}
+
+// MarkAPITokenExpiryWarned records that the expiry warning was sent
+func (s *BaseStore) MarkAPITokenExpiryWarned(ctx context.Context, tokenHash string, at time.Time) error {
+	_, err := s.execContext(ctx, `UPDATE sessions SET expiry_warned_at = ? WHERE token = ?`, at.UTC(), tokenHash)
+	return err
+}
+
+// RecordAPITokenUsage adds request counts to the daily usage rows
+func (s *BaseStore) RecordAPITokenUsage(ctx context.Context, usage []APITokenUsage) error {
+	for _, u := range usage {
+		if u.TokenHash == "" || u.Day == "" || u.Endpoint == "" {
+			continue
+		}
+		_, err := s.execContext(ctx, `
+			INSERT INTO api_token_usage (token, day, endpoint, requests, errors, last_used_at)
+			VALUES (?, ?, ?, ?, ?, ?)
+			ON CONFLICT(token, day, endpoint) DO UPDATE SET
+				requests = api_token_usage.requests + excluded.requests,
+				errors = api_token_usage.errors + excluded.errors,
+				last_used_at = CASE WHEN excluded.last_used_at > api_token_usage.last_used_at
+					THEN excluded.last_used_at ELSE api_token_usage.last_used_at END
+		`, u.TokenHash, u.Day, u.Endpoint, u.Requests, u.Errors, u.LastUsedAt.UTC())
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ListAPITokenUsage returns usage rows since the given day, for one token (by
+// stored hash) or for all tokens when tokenHash is empty
+func (s *BaseStore) ListAPITokenUsage(ctx context.Context, tokenHash string, since time.Time) ([]APITokenUsage, error) {
+	query := `SELECT token, day, endpoint, requests, errors, last_used_at FROM api_token_usage WHERE day >= ?`
+	args := []interface{}{APITokenUsageDay(since)}
+	if tokenHash != "" {
+		query += ` AND token = ?`
+		args = append(args, tokenHash)
+	}
+	query += ` ORDER BY day, endpoint`
+	rows, err := s.queryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []APITokenUsage
+	for rows.Next() {
+		var u APITokenUsage
+		if err := rows.Scan(&u.TokenHash, &u.Day, &u.Endpoint, &u.Requests, &u.Errors, &u.LastUsedAt); err != nil {
+			return nil, err
+		}
+		out = append(out, u)
+	}
+	return out, rows.Err()
+}
+
+// DeleteAPITokenUsageBefore removes usage rows older than the given day
+func (s *BaseStore) DeleteAPITokenUsageBefore(ctx context.Context, before time.Time) (int64, error) {
+	res, err := s.execContext(ctx, `DELETE FROM api_token_usage WHERE day < ?`, APITokenUsageDay(before))
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
@@ -0,0 +1,105 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// ============================================================
+// Billing Period Storage Methods (BaseStore)
+// ============================================================
+
+// FinalizeBillingPeriod locks a tenant's counters for a period. Finalized
+// rows are immutable: if the period was already finalized the stored row is
+// left untouched and ErrBillingPeriodFinalized is returned.
+func (s *BaseStore) FinalizeBillingPeriod(ctx context.Context, bp *BillingPeriod) error {
+	if bp == nil || bp.TenantID == "" || bp.Period == "" {
+		return fmt.Errorf("tenant_id and period are required")
+	}
+	bp.Status = BillingPeriodFinalized
+	if bp.FinalizedAt.IsZero() {
+		bp.FinalizedAt = time.Now().UTC()
+	}
+	if bp.SchemaVersion == 0 {
+		bp.SchemaVersion = BillingSchemaVersion
+	}
+
+	result, err := s.execContext(ctx, `
+		INSERT INTO billing_periods (tenant_id, period, period_start, period_end, devices_managed,
+			pages_mono, pages_color, pages_total, agents_online, agents_total,
+			computed_at, finalized_at, finalized_by, schema_version)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT(tenant_id, period) DO NOTHING
+	`, bp.TenantID, bp.Period, bp.PeriodStart, bp.PeriodEnd, bp.DevicesManaged,
+		bp.PagesMono, bp.PagesColor, bp.PagesTotal, bp.AgentsOnline, bp.AgentsTotal,
+		bp.ComputedAt, bp.FinalizedAt, bp.FinalizedBy, bp.SchemaVersion)
+	if err != nil {
+		return err
+	}
+	if n, err := result.RowsAffected(); err == nil && n == 0 {
+		return ErrBillingPeriodFinalized
+	}
+	return nil
+}
+
+// GetBillingPeriod returns a tenant's finalized period or nil when the period
+// has not been finalized.
+func (s *BaseStore) GetBillingPeriod(ctx context.Context, tenantID, period string) (*BillingPeriod, error) {
+	periods, err := s.ListBillingPeriods(ctx, BillingPeriodFilter{Period: period, TenantIDs: []string{tenantID}})
+	if err != nil || len(periods) == 0 {
+		return nil, err
+	}
+	return periods[0], nil
+}
+
+// ListBillingPeriods lists finalized billing periods, newest period first.
+func (s *BaseStore) ListBillingPeriods(ctx context.Context, filter BillingPeriodFilter) ([]*BillingPeriod, error) {
+	var where []string
+	var args []interface{}
+	if filter.Period != "" {
+		where = append(where, "period = ?")
+		args = append(args, filter.Period)
+	}
+	if len(filter.TenantIDs) > 0 {
+		placeholders := make([]string, len(filter.TenantIDs))
+		for i, id := range filter.TenantIDs {
+			placeholders[i] = "?"
+			args = append(args, id)
+		}
+		where = append(where, "tenant_id IN ("+strings.Join(placeholders, ",")+")")
+	}
+
+	query := `
+		SELECT tenant_id, period, period_start, period_end, devices_managed,
+		       pages_mono, pages_color, pages_total, agents_online, agents_total,
+		       computed_at, finalized_at, finalized_by, schema_version
+		FROM billing_periods`
+	if len(where) > 0 {
+		query += " WHERE " + strings.Join(where, " AND ")
+	}
+	query += " ORDER BY period DESC, tenant_id"
+
+	rows, err := s.queryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var periods []*BillingPeriod
+	for rows.Next() {
+		var bp BillingPeriod
+		var finalizedBy sql.NullString
+		if err := rows.Scan(&bp.TenantID, &bp.Period, &bp.PeriodStart, &bp.PeriodEnd, &bp.DevicesManaged,
+			&bp.PagesMono, &bp.PagesColor, &bp.PagesTotal, &bp.AgentsOnline, &bp.AgentsTotal,
+			&bp.ComputedAt, &bp.FinalizedAt, &finalizedBy, &bp.SchemaVersion); err != nil {
+			return nil, err
+		}
+		bp.FinalizedBy = finalizedBy.String
+		bp.Status = BillingPeriodFinalized
+		periods = append(periods, &bp)
+	}
+	return periods, rows.Err()
+}
@@ -0,0 +1,213 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// ============================================================
+// Agent Config Drift Storage Methods (BaseStore)
+// ============================================================
+
+const agentConfigDriftColumns = `agent_id, tenant_id, expected_version, reported_version, drifted,
+	fields_json, checked_at, drift_since, enforce_requested_at, enforce_requested_by, last_enforced_at`
+
+// SaveAgentConfigDrift stores the latest drift state for an agent, replacing
+// the previous one.
+func (s *BaseStore) SaveAgentConfigDrift(ctx context.Context, d *AgentConfigDrift) error {
+	if d == nil || d.AgentID == "" {
+		return fmt.Errorf("agent_id is required")
+	}
+	fields, err := encodeDriftFields(d.Fields)
+	if err != nil {
+		return err
+	}
+	if d.CheckedAt.IsZero() {
+		d.CheckedAt = time.Now().UTC()
+	}
+	_, err = s.execContext(ctx, `
+		INSERT INTO agent_config_drift (`+agentConfigDriftColumns+`)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT(agent_id) DO UPDATE SET
+			tenant_id = excluded.tenant_id,
+			expected_version = excluded.expected_version,
+			reported_version = excluded.reported_version,
+			drifted = excluded.drifted,
+			fields_json = excluded.fields_json,
+			checked_at = excluded.checked_at,
+			drift_since = excluded.drift_since,
+			enforce_requested_at = excluded.enforce_requested_at,
+			enforce_requested_by = excluded.enforce_requested_by,
+			last_enforced_at = excluded.last_enforced_at
+	`, d.AgentID, nullString(d.TenantID), nullString(d.ExpectedVersion), nullString(d.ReportedVersion),
+		boolToInt(d.Drifted), fields, d.CheckedAt.UTC(), nullTimePtr(d.DriftSince),
+		nullTimePtr(d.EnforceRequestedAt), nullString(d.EnforceRequestedBy), nullTimePtr(d.LastEnforcedAt))
+	return err
+}
+
+// GetAgentConfigDrift returns the drift state for an agent, or nil if the
+// agent has not reported its settings yet.
+func (s *BaseStore) GetAgentConfigDrift(ctx context.Context, agentID string) (*AgentConfigDrift, error) {
+	row := s.queryRowContext(ctx, `SELECT `+agentConfigDriftColumns+` FROM agent_config_drift WHERE agent_id = ?`, agentID)
+	d, err := scanAgentConfigDrift(row)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return d, err
+}
+
+// ListAgentConfigDrift returns drift states, longest-drifting first. A
+// non-empty tenantIDs limits the result to those tenants; driftedOnly skips
+// agents that match policy.
+func (s *BaseStore) ListAgentConfigDrift(ctx context.Context, tenantIDs []string, driftedOnly bool) ([]*AgentConfigDrift, error) {
+	query := `SELECT ` + agentConfigDriftColumns + ` FROM agent_config_drift WHERE 1=1`
+	var args []interface{}
+	if driftedOnly {
+		query += ` AND drifted = ?`
+		args = append(args, boolToInt(true))
+	}
+	if len(tenantIDs) > 0 {
+		query += ` AND tenant_id IN (` + placeholderList(len(tenantIDs)) + `)`
+		for _, id := range tenantIDs {
+			args = append(args, id)
+		}
+	}
+	query += ` ORDER BY drifted DESC, drift_since ASC, agent_id ASC`
+	rows, err := s.queryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []*AgentConfigDrift
+	for rows.Next() {
+		d, err := scanAgentConfigDrift(rows)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, d)
+	}
+	return out, rows.Err()
+}
+
+// AddAgentConfigDriftEvent appends an entry to an agent's drift history.
+func (s *BaseStore) AddAgentConfigDriftEvent(ctx context.Context, e *AgentConfigDriftEvent) error {
+	if e == nil || e.AgentID == "" || e.Event == "" {
+		return fmt.Errorf("agent_id and event are required")
+	}
+	fields, err := encodeDriftFields(e.Fields)
+	if err != nil {
+		return err
+	}
+	if e.CreatedAt.IsZero() {
+		e.CreatedAt = time.Now().UTC()
+	}
+	id, err := s.insertReturningID(ctx, `
+		INSERT INTO agent_config_drift_events (
+			agent_id, tenant_id, event, fields_json, expected_version, reported_version, actor, created_at
+		) VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+	`, e.AgentID, nullString(e.TenantID), e.Event, fields, nullString(e.ExpectedVersion),
+		nullString(e.ReportedVersion), nullString(e.Actor), e.CreatedAt.UTC())
+	if err != nil {
+		return fmt.Errorf("add config drift event: %w", err)
+	}
+	e.ID = id
+	return nil
+}
+
+// ListAgentConfigDriftEvents returns an agent's drift history, newest first.
+// A non-positive limit defaults to 100.
+func (s *BaseStore) ListAgentConfigDriftEvents(ctx context.Context, agentID string, limit int) ([]*AgentConfigDriftEvent, error) {
+	if limit <= 0 {
+		limit = 100
+	}
+	rows, err := s.queryContext(ctx, `
+		SELECT id, agent_id, tenant_id, event, fields_json, expected_version, reported_version, actor, created_at
+		FROM agent_config_drift_events
+		WHERE agent_id = ?
+		ORDER BY created_at DESC, id DESC
+		LIMIT ?
+	`, agentID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []*AgentConfigDriftEvent
+	for rows.Next() {
+		var e AgentConfigDriftEvent
+		var tenantID, fields, expected, reported, actor sql.NullString
+		if err := rows.Scan(&e.ID, &e.AgentID, &tenantID, &e.Event, &fields, &expected, &reported, &actor, &e.CreatedAt); err != nil {
+			return nil, err
+		}
+		if e.Fields, err = decodeDriftFields(fields); err != nil {
+			return nil, err
+		}
+		e.TenantID = tenantID.String
+		e.ExpectedVersion = expected.String
+		e.ReportedVersion = reported.String
+		e.Actor = actor.String
+		out = append(out, &e)
+	}
+	return out, rows.Err()
+}
+
+func scanAgentConfigDrift(row interface{ Scan(...interface{}) error }) (*AgentConfigDrift, error) {
+	var d AgentConfigDrift
+	var tenantID, expected, reported, fields, enforceBy sql.NullString
+	var drifted interface{}
+	var driftSince, enforceAt, enforcedAt sql.NullTime
+	if err := row.Scan(
+		&d.AgentID, &tenantID, &expected, &reported, &drifted,
+		&fields, &d.CheckedAt, &driftSince, &enforceAt, &enforceBy, &enforcedAt,
+	); err != nil {
+		return nil, err
+	}
+	var err error
+	if d.Fields, err = decodeDriftFields(fields); err != nil {
+		return nil, err
+	}
+	d.TenantID = tenantID.String
+	d.ExpectedVersion = expected.String
+	d.ReportedVersion = reported.String
+	d.Drifted = intToBool(drifted)
+	d.EnforceRequestedBy = enforceBy.String
+	if driftSince.Valid {
+		t := driftSince.Time
+		d.DriftSince = &t
+	}
+	if enforceAt.Valid {
+		t := enforceAt.Time
+		d.EnforceRequestedAt = &t
+	}
+	if enforcedAt.Valid {
+		t := enforcedAt.Time
+		d.LastEnforcedAt = &t
+	}
+	return &d, nil
+}
+
+func encodeDriftFields(fields []SettingsDriftField) (sql.NullString, error) {
+	if len(fields) == 0 {
+		return sql.NullString{}, nil
+	}
+	data, err := json.Marshal(fields)
+	if err != nil {
+		return sql.NullString{}, fmt.Errorf("failed to encode drift fields: %w", err)
+	}
+	return sql.NullString{String: string(data), Valid: true}, nil
+}
+
+func decodeDriftFields(raw sql.NullString) ([]SettingsDriftField, error) {
+	if !raw.Valid || raw.String == "" {
+		return nil, nil
+	}
+	var fields []SettingsDriftField
+	if err := json.Unmarshal([]byte(raw.String), &fields); err != nil {
+		return nil, fmt.Errorf("decode drift fields: %w", err)
+	}
+	return fields, nil
+}
@@ -0,0 +1,362 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// ============================================================
+// Deep Scan Storage Methods (BaseStore)
+// ============================================================
+
+const deepScanScheduleColumns = `id, name, enabled, agent_ids, ranges, include_saved,
+	frequency, day_of_week, time_of_day, timezone,
+	next_run_at, last_run_at, last_run_id, created_by, created_at, updated_at`
+
+// CreateDeepScanSchedule stores a new deep scan schedule.
+func (s *BaseStore) CreateDeepScanSchedule(ctx context.Context, schedule *DeepScanSchedule) error {
+	agentIDs, err := json.Marshal(schedule.AgentIDs)
+	if err != nil {
+		return err
+	}
+	now := time.Now().UTC()
+	id, err := s.insertReturningID(ctx, `
+		INSERT INTO deep_scan_schedules (
+			name, enabled, agent_ids, ranges, include_saved,
+			frequency, day_of_week, time_of_day, timezone,
+			next_run_at, created_by, created_at, updated_at
+		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`,
+		schedule.Name, schedule.Enabled, string(agentIDs), schedule.Ranges, schedule.IncludeSaved,
+		schedule.Frequency, schedule.DayOfWeek, schedule.TimeOfDay, schedule.Timezone,
+		schedule.NextRunAt, schedule.CreatedBy, now, now,
+	)
+	if err != nil {
+		return fmt.Errorf("create deep scan schedule: %w", err)
+	}
+	schedule.ID = id
+	schedule.CreatedAt = now
+	schedule.UpdatedAt = now
+	return nil
+}
+
+// UpdateDeepScanSchedule updates a schedule's settings and next run time.
+func (s *BaseStore) UpdateDeepScanSchedule(ctx context.Context, schedule *DeepScanSchedule) error {
+	agentIDs, err := json.Marshal(schedule.AgentIDs)
+	if err != nil {
+		return err
+	}
+	_, err = s.execContext(ctx, `
+		UPDATE deep_scan_schedules SET
+			name = ?, enabled = ?, agent_ids = ?, ranges = ?, include_saved = ?,
+			frequency = ?, day_of_week = ?, time_of_day = ?, timezone = ?,
+			next_run_at = ?, updated_at = ?
+		WHERE id = ?
+	`,
+		schedule.Name, schedule.Enabled, string(agentIDs), schedule.Ranges, schedule.IncludeSaved,
+		schedule.Frequency, schedule.DayOfWeek, schedule.TimeOfDay, schedule.Timezone,
+		schedule.NextRunAt, time.Now().UTC(), schedule.ID,
+	)
+	return err
+}
+
+// GetDeepScanSchedule returns a schedule by ID, or nil if it doesn't exist.
+func (s *BaseStore) GetDeepScanSchedule(ctx context.Context, id int64) (*DeepScanSchedule, error) {
+	row := s.queryRowContext(ctx, `SELECT `+deepScanScheduleColumns+` FROM deep_scan_schedules WHERE id = ?`, id)
+	sched, err := scanDeepScanSchedule(row)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return sched, err
+}
+
+// DeleteDeepScanSchedule deletes a schedule. Its runs are kept.
+func (s *BaseStore) DeleteDeepScanSchedule(ctx context.Context, id int64) error {
+	_, err := s.execContext(ctx, `DELETE FROM deep_scan_schedules WHERE id = ?`, id)
+	return err
+}
+
+// ListDeepScanSchedules returns all schedules ordered by name.
+func (s *BaseStore) ListDeepScanSchedules(ctx context.Context) ([]*DeepScanSchedule, error) {
+	return s.queryDeepScanSchedules(ctx, `SELECT `+deepScanScheduleColumns+` FROM deep_scan_schedules ORDER BY name, id`)
+}
+
+// GetDueDeepScanSchedules returns enabled schedules whose next run is at or
+// before the given time.
+func (s *BaseStore) GetDueDeepScanSchedules(ctx context.Context, before time.Time) ([]*DeepScanSchedule, error) {
+	return s.queryDeepScanSchedules(ctx, `SELECT `+deepScanScheduleColumns+`
+		FROM deep_scan_schedules WHERE enabled = ? AND next_run_at <= ?
+		ORDER BY next_run_at`, true, before)
+}
+
+// UpdateDeepScanScheduleAfterRun records a run and moves the schedule to its
+// next run time.
+func (s *BaseStore) UpdateDeepScanScheduleAfterRun(ctx context.Context, scheduleID, runID int64, nextRun time.Time) error {
+	now := time.Now().UTC()
+	_, err := s.execContext(ctx, `
+		UPDATE deep_scan_schedules SET last_run_at = ?, last_run_id = ?, next_run_at = ?, updated_at = ?
+		WHERE id = ?
+	`, now, runID, nextRun, now, scheduleID)
+	return err
+}
+
+func (s *BaseStore) queryDeepScanSchedules(ctx context.Context, query string, args ...interface{}) ([]*DeepScanSchedule, error) {
+	rows, err := s.queryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("list deep scan schedules: %w", err)
+	}
+	defer rows.Close()
+
+	var schedules []*DeepScanSchedule
+	for rows.Next() {
+		sched, err := scanDeepScanSchedule(rows)
+		if err != nil {
+			return nil, err
+		}
+		schedules = append(schedules, sched)
+	}
+	return schedules, rows.Err()
+}
+
+func scanDeepScanSchedule(row interface{ Scan(...interface{}) error }) (*DeepScanSchedule, error) {
+	var sched DeepScanSchedule
+	var agentIDs, ranges, createdBy sql.NullString
+	var lastRunAt sql.NullTime
+	var lastRunID sql.NullInt64
+	err := row.Scan(
+		&sched.ID, &sched.Name, &sched.Enabled, &agentIDs, &ranges, &sched.IncludeSaved,
+		&sched.Frequency, &sched.DayOfWeek, &sched.TimeOfDay, &sched.Timezone,
+		&sched.NextRunAt, &lastRunAt, &lastRunID, &createdBy, &sched.CreatedAt, &sched.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	if agentIDs.String != "" {
+		_ = json.Unmarshal([]byte(agentIDs.String), &sched.AgentIDs)
+	}
+	if sched.AgentIDs == nil {
+		sched.AgentIDs = []string{}
+	}
+	sched.Ranges = ranges.String
+	sched.CreatedBy = createdBy.String
+	if lastRunAt.Valid {
+		sched.LastRunAt = &lastRunAt.Time
+	}
+	if lastRunID.Valid {
+		sched.LastRunID = &lastRunID.Int64
+	}
+	return &sched, nil
+}
+
+const deepScanRunColumns = `id, schedule_id, name, status, triggered_by, started_at, completed_at,
+	agents_total, agents_completed, agents_failed, devices_scanned, devices_failed, devices_changed`
+
+// CreateDeepScanRun stores a new run.
+func (s *BaseStore) CreateDeepScanRun(ctx context.Context, run *DeepScanRun) error {
+	id, err := s.insertReturningID(ctx, `
+		INSERT INTO deep_scan_runs (
+			schedule_id, name, status, triggered_by, started_at, agents_total
+		) VALUES (?, ?, ?, ?, ?, ?)
+	`, nullInt64Ptr(run.ScheduleID), run.Name, run.Status, run.TriggeredBy, run.StartedAt, run.AgentsTotal)
+	if err != nil {
+		return fmt.Errorf("create deep scan run: %w", err)
+	}
+	run.ID = id
+	return nil
+}
+
+// UpdateDeepScanRun saves a run's status and aggregate counters.
+func (s *BaseStore) UpdateDeepScanRun(ctx context.Context, run *DeepScanRun) error {
+	_, err := s.execContext(ctx, `
+		UPDATE deep_scan_runs SET
+			status = ?, completed_at = ?, agents_total = ?, agents_completed = ?, agents_failed = ?,
+			devices_scanned = ?, devices_failed = ?, devices_changed = ?
+		WHERE id = ?
+	`,
+		run.Status, nullTimePtr(run.CompletedAt), run.AgentsTotal, run.AgentsCompleted, run.AgentsFailed,
+		run.DevicesScanned, run.DevicesFailed, run.DevicesChanged, run.ID,
+	)
+	return err
+}
+
+// GetDeepScanRun returns a run with its per-agent results, or nil if it
+// doesn't exist.
+func (s *BaseStore) GetDeepScanRun(ctx context.Context, id int64) (*DeepScanRun, error) {
+	row := s.queryRowContext(ctx, `SELECT `+deepScanRunColumns+` FROM deep_scan_runs WHERE id = ?`, id)
+	run, err := scanDeepScanRun(row)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	run.Agents, err = s.ListDeepScanAgentResults(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	return run, nil
+}
+
+// ListDeepScanRuns returns the most recent runs, newest first. A non-zero
+// scheduleID limits the list to that schedule's runs.
+func (s *BaseStore) ListDeepScanRuns(ctx context.Context, scheduleID int64, limit int) ([]*DeepScanRun, error) {
+	if limit <= 0 {
+		limit = 50
+	}
+	query := `SELECT ` + deepScanRunColumns + ` FROM deep_scan_runs`
+	var args []interface{}
+	if scheduleID > 0 {
+		query += ` WHERE schedule_id = ?`
+		args = append(args, scheduleID)
+	}
+	query += ` ORDER BY started_at DESC, id DESC LIMIT ?`
+	args = append(args, limit)
+
+	rows, err := s.queryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("list deep scan runs: %w", err)
+	}
+	defer rows.Close()
+
+	var runs []*DeepScanRun
+	for rows.Next() {
+		run, err := scanDeepScanRun(rows)
+		if err != nil {
+			return nil, err
+		}
+		runs = append(runs, run)
+	}
+	return runs, rows.Err()
+}
+
+func scanDeepScanRun(row interface{ Scan(...interface{}) error }) (*DeepScanRun, error) {
+	var run DeepScanRun
+	var scheduleID sql.NullInt64
+	var name, triggeredBy sql.NullString
+	var completedAt sql.NullTime
+	err := row.Scan(
+		&run.ID, &scheduleID, &name, &run.Status, &triggeredBy, &run.StartedAt, &completedAt,
+		&run.AgentsTotal, &run.AgentsCompleted, &run.AgentsFailed,
+		&run.DevicesScanned, &run.DevicesFailed, &run.DevicesChanged,
+	)
+	if err != nil {
+		return nil, err
+	}
+	if scheduleID.Valid {
+		run.ScheduleID = &scheduleID.Int64
+	}
+	run.Name = name.String
+	run.TriggeredBy = triggeredBy.String
+	if completedAt.Valid {
+		run.CompletedAt = &completedAt.Time
+	}
+	return &run, nil
+}
+
+// SaveDeepScanAgentResult inserts an agent result, or updates it when ID is
+// set.
+func (s *BaseStore) SaveDeepScanAgentResult(ctx context.Context, result *DeepScanAgentResult) error {
+	results, err := json.Marshal(result.Results)
+	if err != nil {
+		return err
+	}
+	diffs, err := json.Marshal(result.Diffs)
+	if err != nil {
+		return err
+	}
+	if result.ID == 0 {
+		id, err := s.insertReturningID(ctx, `
+			INSERT INTO deep_scan_agent_results (
+				run_id, agent_id, status, job_id, total, done, failed, error,
+				results_json, diffs_json, started_at, completed_at
+			) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		`,
+			result.RunID, result.AgentID, result.Status, result.JobID, result.Total, result.Done, result.Failed, result.Error,
+			string(results), string(diffs), nullTimePtr(result.StartedAt), nullTimePtr(result.CompletedAt),
+		)
+		if err != nil {
+			return fmt.Errorf("create deep scan agent result: %w", err)
+		}
+		result.ID = id
+		return nil
+	}
+	_, err = s.execContext(ctx, `
+		UPDATE deep_scan_agent_results SET
+			status = ?, job_id = ?, total = ?, done = ?, failed = ?, error = ?,
+			results_json = ?, diffs_json = ?, started_at = ?, completed_at = ?
+		WHERE id = ?
+	`,
+		result.Status, result.JobID, result.Total, result.Done, result.Failed, result.Error,
+		string(results), string(diffs), nullTimePtr(result.StartedAt), nullTimePtr(result.CompletedAt), result.ID,
+	)
+	return err
+}
+
+const deepScanAgentResultColumns = `id, run_id, agent_id, status, job_id, total, done, failed, error,
+	results_json, diffs_json, started_at, completed_at`
+
+// ListDeepScanAgentResults returns the per-agent results of a run.
+func (s *BaseStore) ListDeepScanAgentResults(ctx context.Context, runID int64) ([]*DeepScanAgentResult, error) {
+	rows, err := s.queryContext(ctx, `SELECT `+deepScanAgentResultColumns+`
+		FROM deep_scan_agent_results WHERE run_id = ? ORDER BY agent_id`, runID)
+	if err != nil {
+		return nil, fmt.Errorf("list deep scan agent results: %w", err)
+	}
+	defer rows.Close()
+
+	var results []*DeepScanAgentResult
+	for rows.Next() {
+		result, err := scanDeepScanAgentResult(rows)
+		if err != nil {
+			return nil, err
+		}
+		results = append(results, result)
+	}
+	return results, rows.Err()
+}
+
+// GetPreviousDeepScanAgentResult returns the agent's most recent completed
+// result from a run before runID, used as the baseline for diffs.
+func (s *BaseStore) GetPreviousDeepScanAgentResult(ctx context.Context, agentID string, runID int64) (*DeepScanAgentResult, error) {
+	row := s.queryRowContext(ctx, `SELECT `+deepScanAgentResultColumns+`
+		FROM deep_scan_agent_results
+		WHERE agent_id = ? AND run_id < ? AND status IN (?, ?)
+		ORDER BY run_id DESC LIMIT 1`, agentID, runID, DeepScanStatusCompleted, DeepScanStatusPartial)
+	result, err := scanDeepScanAgentResult(row)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return result, err
+}
+
+func scanDeepScanAgentResult(row interface{ Scan(...interface{}) error }) (*DeepScanAgentResult, error) {
+	var result DeepScanAgentResult
+	var jobID, errMsg, results, diffs sql.NullString
+	var startedAt, completedAt sql.NullTime
+	err := row.Scan(
+		&result.ID, &result.RunID, &result.AgentID, &result.Status, &jobID,
+		&result.Total, &result.Done, &result.Failed, &errMsg,
+		&results, &diffs, &startedAt, &completedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	result.JobID = jobID.String
+	result.Error = errMsg.String
+	if results.String != "" {
+		_ = json.Unmarshal([]byte(results.String), &result.Results)
+	}
+	if diffs.String != "" {
+		_ = json.Unmarshal([]byte(diffs.String), &result.Diffs)
+	}
+	if startedAt.Valid {
+		result.StartedAt = &startedAt.Time
+	}
+	if completedAt.Valid {
+		result.CompletedAt = &completedAt.Time
+	}
+	return &result, nil
+}
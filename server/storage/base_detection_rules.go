@@ -0,0 +1,112 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// ============================================================
+// Detection Rule Storage Methods (BaseStore)
+// ============================================================
+
+const detectionRuleColumns = `id, manufacturer, sys_object_id_prefix, sys_descr_regex, model_regex,
+	vendor_module, priority, enabled, description, created_by, created_at, updated_at`
+
+// CreateDetectionRule validates and stores a new rule.
+func (s *BaseStore) CreateDetectionRule(ctx context.Context, r *DetectionRule) error {
+	if err := r.Validate(); err != nil {
+		return err
+	}
+	now := time.Now().UTC()
+	id, err := s.insertReturningID(ctx, `
+		INSERT INTO detection_rules (
+			manufacturer, sys_object_id_prefix, sys_descr_regex, model_regex, vendor_module,
+			priority, enabled, description, created_by, created_at, updated_at
+		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`, r.Manufacturer, r.SysObjectIDPrefix, r.SysDescrRegex, r.ModelRegex, r.VendorModule,
+		r.Priority, r.Enabled, nullString(r.Description), nullString(r.CreatedBy), now, now)
+	if err != nil {
+		return fmt.Errorf("create detection rule: %w", err)
+	}
+	r.ID = id
+	r.CreatedAt, r.UpdatedAt = now, now
+	return nil
+}
+
+// UpdateDetectionRule validates and saves an existing rule.
+func (s *BaseStore) UpdateDetectionRule(ctx context.Context, r *DetectionRule) error {
+	if err := r.Validate(); err != nil {
+		return err
+	}
+	now := time.Now().UTC()
+	res, err := s.execContext(ctx, `
+		UPDATE detection_rules SET
+			manufacturer = ?, sys_object_id_prefix = ?, sys_descr_regex = ?, model_regex = ?,
+			vendor_module = ?, priority = ?, enabled = ?, description = ?, updated_at = ?
+		WHERE id = ?
+	`, r.Manufacturer, r.SysObjectIDPrefix, r.SysDescrRegex, r.ModelRegex, r.VendorModule,
+		r.Priority, r.Enabled, nullString(r.Description), now, r.ID)
+	if err != nil {
+		return fmt.Errorf("update detection rule: %w", err)
+	}
+	if affected, _ := res.RowsAffected(); affected == 0 {
+		return fmt.Errorf("detection rule not found")
+	}
+	r.UpdatedAt = now
+	return nil
+}
+
+// GetDetectionRule returns a rule, or nil if it doesn't exist.
+func (s *BaseStore) GetDetectionRule(ctx context.Context, id int64) (*DetectionRule, error) {
+	row := s.queryRowContext(ctx, `SELECT `+detectionRuleColumns+` FROM detection_rules WHERE id = ?`, id)
+	r, err := scanDetectionRule(row)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return r, err
+}
+
+// ListDetectionRules returns all rules, highest priority first.
+func (s *BaseStore) ListDetectionRules(ctx context.Context) ([]*DetectionRule, error) {
+	rows, err := s.queryContext(ctx, `SELECT `+detectionRuleColumns+` FROM detection_rules ORDER BY priority DESC, id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var rules []*DetectionRule
+	for rows.Next() {
+		r, err := scanDetectionRule(rows)
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, r)
+	}
+	return rules, rows.Err()
+}
+
+// DeleteDetectionRule removes a rule.
+func (s *BaseStore) DeleteDetectionRule(ctx context.Context, id int64) error {
+	res, err := s.execContext(ctx, `DELETE FROM detection_rules WHERE id = ?`, id)
+	if err != nil {
+		return err
+	}
+	if affected, _ := res.RowsAffected(); affected == 0 {
+		return fmt.Errorf("detection rule not found")
+	}
+	return nil
+}
+
+func scanDetectionRule(row interface{ Scan(...interface{}) error }) (*DetectionRule, error) {
+	var r DetectionRule
+	var description, createdBy sql.NullString
+	if err := row.Scan(&r.ID, &r.Manufacturer, &r.SysObjectIDPrefix, &r.SysDescrRegex, &r.ModelRegex,
+		&r.VendorModule, &r.Priority, &r.Enabled, &description, &createdBy, &r.CreatedAt, &r.UpdatedAt); err != nil {
+		return nil, err
+	}
+	r.Description = description.String
+	r.CreatedBy = createdBy.String
+	return &r, nil
+}
@@ -0,0 +1,215 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// ============================================================
+// Device Approval Storage Methods (BaseStore)
+// ============================================================
+
+// GetDeviceApprovalPolicy returns the approval policy for a tenant. When no
+// policy has been saved a disabled policy is returned.
+func (s *BaseStore) GetDeviceApprovalPolicy(ctx context.Context, tenantID string) (*DeviceApprovalPolicy, error) {
+	row := s.queryRowContext(ctx, `
+		SELECT tenant_id, enabled, auto_approve_manufacturers, auto_approve_models, updated_at, updated_by
+		FROM device_approval_policies WHERE tenant_id = ?
+	`, tenantID)
+
+	var policy DeviceApprovalPolicy
+	var manufacturers, models, updatedBy sql.NullString
+	err := row.Scan(&policy.TenantID, &policy.Enabled, &manufacturers, &models, &policy.UpdatedAt, &updatedBy)
+	if err == sql.ErrNoRows {
+		return &DeviceApprovalPolicy{TenantID: tenantID}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if manufacturers.Valid && manufacturers.String != "" {
+		_ = json.Unmarshal([]byte(manufacturers.String), &policy.AutoApproveManufacturers)
+	}
+	if models.Valid && models.String != "" {
+		_ = json.Unmarshal([]byte(models.String), &policy.AutoApproveModels)
+	}
+	policy.UpdatedBy = updatedBy.String
+	return &policy, nil
+}
+
+// UpsertDeviceApprovalPolicy creates or replaces a tenant's approval policy.
+func (s *BaseStore) UpsertDeviceApprovalPolicy(ctx context.Context, policy *DeviceApprovalPolicy) error {
+	if policy == nil {
+		return fmt.Errorf("policy is required")
+	}
+	manufacturers, _ := json.Marshal(policy.AutoApproveManufacturers)
+	models, _ := json.Marshal(policy.AutoApproveModels)
+	policy.UpdatedAt = time.Now().UTC()
+
+	_, err := s.execContext(ctx, `
+		INSERT INTO device_approval_policies (tenant_id, enabled, auto_approve_manufacturers, auto_approve_models, updated_at, updated_by)
+		VALUES (?, ?, ?, ?, ?, ?)
+		ON CONFLICT(tenant_id) DO UPDATE SET
+			enabled = excluded.enabled,
+			auto_approve_manufacturers = excluded.auto_approve_manufacturers,
+			auto_approve_models = excluded.auto_approve_models,
+			updated_at = excluded.updated_at,
+			updated_by = excluded.updated_by
+	`, policy.TenantID, policy.Enabled, string(manufacturers), string(models), policy.UpdatedAt, policy.UpdatedBy)
+	return err
+}
+
+// CreateDeviceApproval records the approval state for a newly seen device.
+// Existing records are left untouched so re-uploads never reset a decision.
+func (s *BaseStore) CreateDeviceApproval(ctx context.Context, approval *DeviceApproval) error {
+	if approval == nil || approval.Serial == "" {
+		return fmt.Errorf("serial is required")
+	}
+	if approval.Status == "" {
+		approval.Status = DeviceApprovalPending
+	}
+	now := time.Now().UTC()
+	approval.CreatedAt = now
+
+	var reviewedAt interface{}
+	if !approval.ReviewedAt.IsZero() {
+		reviewedAt = approval.ReviewedAt
+	}
+	_, err := s.execContext(ctx, `
+		INSERT INTO device_approvals (serial, tenant_id, agent_id, status, manufacturer, model, ip, reason, created_at, reviewed_at, reviewed_by, notes)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT(serial) DO NOTHING
+	`, approval.Serial, approval.TenantID, approval.AgentID, approval.Status, approval.Manufacturer, approval.Model,
+		approval.IP, approval.Reason, now, reviewedAt, approval.ReviewedBy, approval.Notes)
+	return err
+}
+
+// GetDeviceApproval returns the approval record for a device or nil when none exists.
+func (s *BaseStore) GetDeviceApproval(ctx context.Context, serial string) (*DeviceApproval, error) {
+	rows, err := s.queryContext(ctx, deviceApprovalSelect+` WHERE serial = ?`, serial)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	approvals, err := scanDeviceApprovals(rows)
+	if err != nil || len(approvals) == 0 {
+		return nil, err
+	}
+	return approvals[0], nil
+}
+
+// ListDeviceApprovals lists approval records, newest first.
+func (s *BaseStore) ListDeviceApprovals(ctx context.Context, filter DeviceApprovalFilter) ([]*DeviceApproval, error) {
+	var where []string
+	var args []interface{}
+	if filter.Status != "" {
+		where = append(where, "status = ?")
+		args = append(args, filter.Status)
+	}
+	if len(filter.TenantIDs) > 0 {
+		placeholders := make([]string, len(filter.TenantIDs))
+		for i, id := range filter.TenantIDs {
+			placeholders[i] = "?"
+			args = append(args, id)
+		}
+		where = append(where, "tenant_id IN ("+strings.Join(placeholders, ",")+")")
+	}
+
+	query := deviceApprovalSelect
+	if len(where) > 0 {
+		query += " WHERE " + strings.Join(where, " AND ")
+	}
+	query += " ORDER BY created_at DESC"
+	if filter.Limit > 0 {
+		query += fmt.Sprintf(" LIMIT %d", filter.Limit)
+	}
+
+	rows, err := s.queryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	return scanDeviceApprovals(rows)
+}
+
+// SetDeviceApprovalStatus updates the status of the given devices (bulk
+// approve/reject) and returns the number of records changed.
+func (s *BaseStore) SetDeviceApprovalStatus(ctx context.Context, serials []string, status, reviewedBy, notes string) (int64, error) {
+	switch status {
+	case DeviceApprovalPending, DeviceApprovalApproved, DeviceApprovalRejected:
+	default:
+		return 0, fmt.Errorf("invalid approval status: %q", status)
+	}
+	if len(serials) == 0 {
+		return 0, nil
+	}
+
+	placeholders := make([]string, len(serials))
+	args := []interface{}{status, time.Now().UTC(), reviewedBy, notes}
+	for i, serial := range serials {
+		placeholders[i] = "?"
+		args = append(args, serial)
+	}
+	result, err := s.execContext(ctx, `
+		UPDATE device_approvals
+		SET status = ?, reviewed_at = ?, reviewed_by = ?, notes = ?, reason = 'manual review'
+		WHERE serial IN (`+strings.Join(placeholders, ",")+`)
+	`, args...)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// ListUnapprovedDeviceSerials returns the serials of devices that are pending
+// or rejected and must be excluded from reports and alerting.
+func (s *BaseStore) ListUnapprovedDeviceSerials(ctx context.Context) (map[string]struct{}, error) {
+	rows, err := s.queryContext(ctx, `SELECT serial FROM device_approvals WHERE status != ?`, DeviceApprovalApproved)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	serials := make(map[string]struct{})
+	for rows.Next() {
+		var serial string
+		if err := rows.Scan(&serial); err != nil {
+			return nil, err
+		}
+		serials[serial] = struct{}{}
+	}
+	return serials, rows.Err()
+}
+
+const deviceApprovalSelect = `
+	SELECT serial, tenant_id, agent_id, status, manufacturer, model, ip, reason,
+	       created_at, reviewed_at, reviewed_by, notes
+	FROM device_approvals`
+
+func scanDeviceApprovals(rows *sql.Rows) ([]*DeviceApproval, error) {
+	var approvals []*DeviceApproval
+	for rows.Next() {
+		var a DeviceApproval
+		var tenantID, manufacturer, model, ip, reason, reviewedBy, notes sql.NullString
+		var reviewedAt sql.NullTime
+		if err := rows.Scan(&a.Serial, &tenantID, &a.AgentID, &a.Status, &manufacturer, &model, &ip, &reason,
+			&a.CreatedAt, &reviewedAt, &reviewedBy, &notes); err != nil {
+			return nil, err
+		}
+		a.TenantID = tenantID.String
+		a.Manufacturer = manufacturer.String
+		a.Model = model.String
+		a.IP = ip.String
+		a.Reason = reason.String
+		if reviewedAt.Valid {
+			a.ReviewedAt = reviewedAt.Time
+		}
+		a.ReviewedBy = reviewedBy.String
+		a.Notes = notes.String
+		approvals = append(approvals, &a)
+	}
+	return approvals, rows.Err()
+}
@@ -0,0 +1,128 @@
+package storage
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"strings"
+	"time"
+)
+
+// recordDeviceChange appends an entry to the change feed. For upserts,
+// content is hashed and compared with the last entry for the same serial and
+// kind so unchanged writes do not flood the feed.
+func (s *BaseStore) recordDeviceChange(ctx context.Context, serial, agentID, kind, op string, content interface{}) error {
+	var hash string
+	if op == DeviceChangeOpUpsert {
+		b, err := json.Marshal(content)
+		if err != nil {
+			return err
+		}
+		sum := sha256.Sum256(b)
+		hash = hex.EncodeToString(sum[:])
+
+		var lastOp string
+		var lastHash sql.NullString
+		err = s.queryRowContext(ctx, `
+			SELECT op, content_hash FROM device_changes
+			WHERE serial = ? AND kind = ?
+			ORDER BY id DESC LIMIT 1
+		`, serial, kind).Scan(&lastOp, &lastHash)
+		if err != nil && err != sql.ErrNoRows {
+			return err
+		}
+		if err == nil && lastOp == DeviceChangeOpUpsert && lastHash.String == hash {
+			return nil
+		}
+	}
+
+	_, err := s.execContext(ctx, `
+		INSERT INTO device_changes (serial, agent_id, kind, op, content_hash, changed_at)
+		VALUES (?, ?, ?, ?, ?, ?)
+	`, serial, nullString(agentID), kind, op, nullString(hash), time.Now().UTC())
+	return err
+}
+
+// deviceChangeContent is the part of a device that counts as a change;
+// sighting timestamps are left out.
+func deviceChangeContent(device *Device) interface{} {
+	d := *device
+	d.LastSeen = time.Time{}
+	d.FirstSeen = time.Time{}
+	d.CreatedAt = time.Time{}
+	return &d
+}
+
+// metricsChangeContent is the part of a metrics snapshot that counts as a
+// change: the counters and supply levels, not when they were read.
+func metricsChangeContent(m *MetricsSnapshot) interface{} {
+	c := *m
+	c.ID = 0
+	c.Timestamp = time.Time{}
+	c.Tier = ""
+	return &c
+}
+
+// ListDeviceChanges returns change feed entries after filter.After in cursor
+// order.
+func (s *BaseStore) ListDeviceChanges(ctx context.Context, filter DeviceChangeFilter) ([]*DeviceChange, error) {
+	where := []string{"id > ?"}
+	args := []interface{}{filter.After}
+	if len(filter.AgentIDs) > 0 {
+		where = append(where, "agent_id IN ("+placeholderList(len(filter.AgentIDs))+")")
+		for _, id := range filter.AgentIDs {
+			args = append(args, id)
+		}
+	}
+	if len(filter.Kinds) > 0 {
+		where = append(where, "kind IN ("+placeholderList(len(filter.Kinds))+")")
+		for _, k := range filter.Kinds {
+			args = append(args, k)
+		}
+	}
+
+	query := `SELECT id, serial, agent_id, kind, op, changed_at FROM device_changes WHERE ` +
+		strings.Join(where, " AND ") + ` ORDER BY id`
+	if filter.Limit > 0 {
+		query += " LIMIT ?"
+		args = append(args, filter.Limit)
+	}
+
+	rows, err := s.queryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var changes []*DeviceChange
+	for rows.Next() {
+		var c DeviceChange
+		var agentID sql.NullString
+		if err := rows.Scan(&c.ID, &c.Serial, &agentID, &c.Kind, &c.Op, &c.ChangedAt); err != nil {
+			return nil, err
+		}
+		c.AgentID = agentID.String
+		changes = append(changes, &c)
+	}
+	return changes, rows.Err()
+}
+
+// GetDeviceChangeBounds returns the oldest and newest change IDs still kept,
+// or zeros when the feed is empty. Cursors below oldest-1 point at pruned
+// history.
+func (s *BaseStore) GetDeviceChangeBounds(ctx context.Context) (oldest, newest int64, err error) {
+	var minID, maxID sql.NullInt64
+	err = s.queryRowContext(ctx, `SELECT MIN(id), MAX(id) FROM device_changes`).Scan(&minID, &maxID)
+	return minID.Int64, maxID.Int64, err
+}
+
+// DeleteDeviceChangesBefore prunes change feed entries recorded before cutoff.
+func (s *BaseStore) DeleteDeviceChangesBefore(ctx context.Context, cutoff time.Time) (int64, error) {
+	res, err := s.execContext(ctx, `DELETE FROM device_changes WHERE changed_at < ?`, cutoff.UTC())
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
@@ -0,0 +1,174 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// ============================================================
+// Device Notes & Maintenance Log Storage Methods (BaseStore)
+// ============================================================
+
+const deviceNoteColumns = `id, serial, tenant_id, kind, body, part, vendor,
+	firmware_from, firmware_to, page_count, author, occurred_at, created_at, updated_at`
+
+// AddDeviceNote stores a new note or maintenance entry. OccurredAt defaults
+// to now.
+func (s *BaseStore) AddDeviceNote(ctx context.Context, n *DeviceNote) error {
+	if err := n.Validate(); err != nil {
+		return err
+	}
+	now := time.Now().UTC()
+	if n.OccurredAt.IsZero() {
+		n.OccurredAt = now
+	}
+	id, err := s.insertReturningID(ctx, `
+		INSERT INTO device_notes (
+			serial, tenant_id, kind, body, part, vendor, firmware_from, firmware_to,
+			page_count, author, occurred_at, created_at, updated_at
+		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`,
+		n.Serial, nullString(n.TenantID), n.Kind, nullString(n.Body), nullString(n.Part), nullString(n.Vendor),
+		nullString(n.FirmwareFrom), nullString(n.FirmwareTo), nullInt64Ptr(n.PageCount), n.Author,
+		n.OccurredAt.UTC(), now, now,
+	)
+	if err != nil {
+		return fmt.Errorf("add device note: %w", err)
+	}
+	n.ID = id
+	n.OccurredAt = n.OccurredAt.UTC()
+	n.CreatedAt, n.UpdatedAt = now, now
+	return nil
+}
+
+// GetDeviceNote returns a note by ID, or nil if it doesn't exist.
+func (s *BaseStore) GetDeviceNote(ctx context.Context, id int64) (*DeviceNote, error) {
+	row := s.queryRowContext(ctx, `SELECT `+deviceNoteColumns+` FROM device_notes WHERE id = ?`, id)
+	n, err := scanDeviceNote(row)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return n, err
+}
+
+// UpdateDeviceNote rewrites the content of an existing note. The serial,
+// tenant and author are kept.
+func (s *BaseStore) UpdateDeviceNote(ctx context.Context, n *DeviceNote) error {
+	if err := n.Validate(); err != nil {
+		return err
+	}
+	if n.OccurredAt.IsZero() {
+		return fmt.Errorf("occurred_at is required")
+	}
+	now := time.Now().UTC()
+	res, err := s.execContext(ctx, `
+		UPDATE device_notes SET kind = ?, body = ?, part = ?, vendor = ?, firmware_from = ?,
+			firmware_to = ?, page_count = ?, occurred_at = ?, updated_at = ?
+		WHERE id = ?
+	`,
+		n.Kind, nullString(n.Body), nullString(n.Part), nullString(n.Vendor), nullString(n.FirmwareFrom),
+		nullString(n.FirmwareTo), nullInt64Ptr(n.PageCount), n.OccurredAt.UTC(), now, n.ID,
+	)
+	if err != nil {
+		return err
+	}
+	if affected, _ := res.RowsAffected(); affected == 0 {
+		return fmt.Errorf("device note not found")
+	}
+	n.UpdatedAt = now
+	return nil
+}
+
+// DeleteDeviceNote removes a note.
+func (s *BaseStore) DeleteDeviceNote(ctx context.Context, id int64) error {
+	res, err := s.execContext(ctx, `DELETE FROM device_notes WHERE id = ?`, id)
+	if err != nil {
+		return err
+	}
+	if affected, _ := res.RowsAffected(); affected == 0 {
+		return fmt.Errorf("device note not found")
+	}
+	return nil
+}
+
+// ListDeviceNotes returns notes matching filter, most recent first.
+func (s *BaseStore) ListDeviceNotes(ctx context.Context, filter DeviceNoteFilter) ([]*DeviceNote, error) {
+	var where []string
+	var args []interface{}
+	if filter.Serial != "" {
+		where = append(where, "serial = ?")
+		args = append(args, filter.Serial)
+	}
+	if len(filter.TenantIDs) > 0 {
+		where = append(where, "tenant_id IN ("+placeholderList(len(filter.TenantIDs))+")")
+		for _, id := range filter.TenantIDs {
+			args = append(args, id)
+		}
+	}
+	if len(filter.Kinds) > 0 {
+		where = append(where, "kind IN ("+placeholderList(len(filter.Kinds))+")")
+		for _, k := range filter.Kinds {
+			args = append(args, k)
+		}
+	}
+	if !filter.Since.IsZero() {
+		where = append(where, "occurred_at >= ?")
+		args = append(args, filter.Since.UTC())
+	}
+	if !filter.Until.IsZero() {
+		where = append(where, "occurred_at < ?")
+		args = append(args, filter.Until.UTC())
+	}
+
+	query := `SELECT ` + deviceNoteColumns + ` FROM device_notes`
+	if len(where) > 0 {
+		query += " WHERE " + strings.Join(where, " AND ")
+	}
+	query += " ORDER BY occurred_at DESC, id DESC"
+	if filter.Limit > 0 {
+		query += " LIMIT ?"
+		args = append(args, filter.Limit)
+	}
+
+	rows, err := s.queryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var notes []*DeviceNote
+	for rows.Next() {
+		n, err := scanDeviceNote(rows)
+		if err != nil {
+			return nil, err
+		}
+		notes = append(notes, n)
+	}
+	return notes, rows.Err()
+}
+
+func scanDeviceNote(row interface{ Scan(...interface{}) error }) (*DeviceNote, error) {
+	var n DeviceNote
+	var tenantID, body, part, vendor, fwFrom, fwTo sql.NullString
+	var pageCount sql.NullInt64
+	if err := row.Scan(
+		&n.ID, &n.Serial, &tenantID, &n.Kind, &body, &part, &vendor,
+		&fwFrom, &fwTo, &pageCount, &n.Author, &n.OccurredAt, &n.CreatedAt, &n.UpdatedAt,
+	); err != nil {
+		return nil, err
+	}
+	n.TenantID = tenantID.String
+	n.Body = body.String
+	n.Part = part.String
+	n.Vendor = vendor.String
+	n.FirmwareFrom = fwFrom.String
+	n.FirmwareTo = fwTo.String
+	if pageCount.Valid {
+		v := pageCount.Int64
+		n.PageCount = &v
+	}
+	return &n, nil
+}
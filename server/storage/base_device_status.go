@@ -0,0 +1,65 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"printmaster/server/devicestatus"
+)
+
+// ============================================================
+// Device Status Rule Storage Methods (BaseStore)
+// ============================================================
+
+// ListDeviceStatusRules returns admin-defined status rules in evaluation order.
+func (s *BaseStore) ListDeviceStatusRules(ctx context.Context) ([]devicestatus.Rule, error) {
+	rows, err := s.queryContext(ctx, `
+		SELECT id, vendor, pattern, state, description
+		FROM device_status_rules ORDER BY position, id
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var rules []devicestatus.Rule
+	for rows.Next() {
+		var r devicestatus.Rule
+		var vendor, description sql.NullString
+		var state string
+		if err := rows.Scan(&r.ID, &vendor, &r.Pattern, &state, &description); err != nil {
+			return nil, err
+		}
+		r.Vendor = vendor.String
+		r.State = devicestatus.State(state)
+		r.Description = description.String
+		rules = append(rules, r)
+	}
+	return rules, rows.Err()
+}
+
+// ReplaceDeviceStatusRules atomically replaces all admin-defined status rules.
+// Rules are stored in the order given.
+func (s *BaseStore) ReplaceDeviceStatusRules(ctx context.Context, rules []devicestatus.Rule) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, s.query(`DELETE FROM device_status_rules`)); err != nil {
+		return err
+	}
+	now := time.Now().UTC()
+	insert := s.query(`
+		INSERT INTO device_status_rules (position, vendor, pattern, state, description, created_at)
+		VALUES (?, ?, ?, ?, ?, ?)
+	`)
+	for i, r := range rules {
+		if _, err := tx.ExecContext(ctx, insert, i, r.Vendor, r.Pattern, string(r.State), r.Description, now); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
@@ -0,0 +1,77 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// ============================================================
+// Feature Flag Storage Methods (BaseStore)
+// ============================================================
+
+// ListFeatureFlags returns the explicit flag values for one scope: the
+// server-wide defaults when tenantID is empty, otherwise that tenant's
+// overrides.
+func (s *BaseStore) ListFeatureFlags(ctx context.Context, tenantID string) ([]*FeatureFlag, error) {
+	rows, err := s.queryContext(ctx, `
+		SELECT tenant_id, flag, enabled, updated_at, COALESCE(updated_by, '')
+		FROM feature_flags WHERE tenant_id = ? ORDER BY flag
+	`, strings.TrimSpace(tenantID))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var flags []*FeatureFlag
+	for rows.Next() {
+		var (
+			f         FeatureFlag
+			enabled   interface{}
+			updatedAt sql.NullTime
+		)
+		if err := rows.Scan(&f.TenantID, &f.Key, &enabled, &updatedAt, &f.UpdatedBy); err != nil {
+			return nil, err
+		}
+		f.Enabled = intToBool(enabled)
+		if updatedAt.Valid {
+			f.UpdatedAt = updatedAt.Time
+		}
+		flags = append(flags, &f)
+	}
+	return flags, rows.Err()
+}
+
+// SetFeatureFlag stores an explicit flag value for the server default
+// (empty TenantID) or a tenant.
+func (s *BaseStore) SetFeatureFlag(ctx context.Context, flag *FeatureFlag) error {
+	if flag == nil || strings.TrimSpace(flag.Key) == "" {
+		return fmt.Errorf("feature flag key required")
+	}
+	flag.TenantID = strings.TrimSpace(flag.TenantID)
+	flag.UpdatedAt = time.Now().UTC()
+	if flag.UpdatedBy == "" {
+		flag.UpdatedBy = "system"
+	}
+	_, err := s.execContext(ctx, `
+		INSERT INTO feature_flags (tenant_id, flag, enabled, updated_at, updated_by)
+		VALUES (?, ?, ?, ?, ?)
+		ON CONFLICT(tenant_id, flag) DO UPDATE SET
+			enabled = excluded.enabled,
+			updated_at = excluded.updated_at,
+			updated_by = excluded.updated_by
+	`, flag.TenantID, flag.Key, boolToInt(flag.Enabled), flag.UpdatedAt, flag.UpdatedBy)
+	return err
+}
+
+// DeleteFeatureFlag removes an explicit value so the scope inherits again.
+func (s *BaseStore) DeleteFeatureFlag(ctx context.Context, tenantID, key string) error {
+	if strings.TrimSpace(key) == "" {
+		return fmt.Errorf("feature flag key required")
+	}
+	_, err := s.execContext(ctx, `DELETE FROM feature_flags WHERE tenant_id = ? AND flag = ?`,
+		strings.TrimSpace(tenantID), key)
+	return err
+}
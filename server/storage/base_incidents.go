@@ -0,0 +1,305 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// ============================================================
+// Incident Storage Methods (BaseStore)
+// ============================================================
+
+const incidentColumns = `id, tenant_id, title, description, severity, status, assignee,
+	device_serial, agent_id, source, correlation_key, created_by, opened_at, updated_at, resolved_at`
+
+// CreateIncident stores a new incident and links its AlertIDs.
+func (s *BaseStore) CreateIncident(ctx context.Context, inc *Incident) error {
+	if err := inc.Validate(); err != nil {
+		return err
+	}
+	now := time.Now().UTC()
+	if inc.OpenedAt.IsZero() {
+		inc.OpenedAt = now
+	}
+	if inc.Status == IncidentStatusResolved && inc.ResolvedAt == nil {
+		inc.ResolvedAt = &now
+	}
+	id, err := s.insertReturningID(ctx, `
+		INSERT INTO incidents (
+			tenant_id, title, description, severity, status, assignee, device_serial,
+			agent_id, source, correlation_key, created_by, opened_at, updated_at, resolved_at
+		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`,
+		nullString(inc.TenantID), inc.Title, nullString(inc.Description), inc.Severity, inc.Status,
+		nullString(inc.Assignee), nullString(inc.DeviceSerial), nullString(inc.AgentID), inc.Source,
+		nullString(inc.Key), nullString(inc.CreatedBy), inc.OpenedAt.UTC(), now, inc.ResolvedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("create incident: %w", err)
+	}
+	inc.ID = id
+	inc.OpenedAt = inc.OpenedAt.UTC()
+	inc.UpdatedAt = now
+	alertIDs := inc.AlertIDs
+	inc.AlertIDs = nil
+	if len(alertIDs) > 0 {
+		if err := s.LinkIncidentAlerts(ctx, id, alertIDs); err != nil {
+			return err
+		}
+	}
+	inc.AlertIDs = uniqueInt64s(alertIDs)
+	return nil
+}
+
+// GetIncident returns an incident with its linked alert IDs, or nil if it
+// doesn't exist.
+func (s *BaseStore) GetIncident(ctx context.Context, id int64) (*Incident, error) {
+	row := s.queryRowContext(ctx, `SELECT `+incidentColumns+` FROM incidents WHERE id = ?`, id)
+	inc, err := scanIncident(row)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := s.loadIncidentAlertIDs(ctx, []*Incident{inc}); err != nil {
+		return nil, err
+	}
+	return inc, nil
+}
+
+// UpdateIncident saves the editable fields of an incident: title,
+// description, severity, status and assignee. ResolvedAt is set when the
+// status becomes resolved and cleared when the incident is reopened.
+func (s *BaseStore) UpdateIncident(ctx context.Context, inc *Incident) error {
+	if err := inc.Validate(); err != nil {
+		return err
+	}
+	now := time.Now().UTC()
+	if inc.Status == IncidentStatusResolved {
+		if inc.ResolvedAt == nil {
+			inc.ResolvedAt = &now
+		}
+	} else {
+		inc.ResolvedAt = nil
+	}
+	res, err := s.execContext(ctx, `
+		UPDATE incidents SET title = ?, description = ?, severity = ?, status = ?, assignee = ?,
+			resolved_at = ?, updated_at = ?
+		WHERE id = ?
+	`,
+		inc.Title, nullString(inc.Description), inc.Severity, inc.Status, nullString(inc.Assignee),
+		inc.ResolvedAt, now, inc.ID,
+	)
+	if err != nil {
+		return err
+	}
+	if affected, _ := res.RowsAffected(); affected == 0 {
+		return fmt.Errorf("incident not found")
+	}
+	inc.UpdatedAt = now
+	return nil
+}
+
+// ListIncidents returns incidents matching filter, most recently opened
+// first, with their linked alert IDs.
+func (s *BaseStore) ListIncidents(ctx context.Context, filter IncidentFilter) ([]*Incident, error) {
+	var where []string
+	var args []interface{}
+	if len(filter.TenantIDs) > 0 {
+		where = append(where, "tenant_id IN ("+placeholderList(len(filter.TenantIDs))+")")
+		for _, id := range filter.TenantIDs {
+			args = append(args, id)
+		}
+	}
+	if len(filter.Statuses) > 0 {
+		where = append(where, "status IN ("+placeholderList(len(filter.Statuses))+")")
+		for _, st := range filter.Statuses {
+			args = append(args, st)
+		}
+	}
+	if filter.OpenOnly {
+		where = append(where, "status <> ?")
+		args = append(args, IncidentStatusResolved)
+	}
+	if filter.Assignee != "" {
+		where = append(where, "assignee = ?")
+		args = append(args, filter.Assignee)
+	}
+	if filter.DeviceSerial != "" {
+		where = append(where, "device_serial = ?")
+		args = append(args, filter.DeviceSerial)
+	}
+	if filter.Key != "" {
+		where = append(where, "correlation_key = ?")
+		args = append(args, filter.Key)
+	}
+
+	query := `SELECT ` + incidentColumns + ` FROM incidents`
+	if len(where) > 0 {
+		query += " WHERE " + strings.Join(where, " AND ")
+	}
+	query += " ORDER BY opened_at DESC, id DESC"
+	if filter.Limit > 0 {
+		query += " LIMIT ?"
+		args = append(args, filter.Limit)
+	}
+
+	rows, err := s.queryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var incidents []*Incident
+	for rows.Next() {
+		inc, err := scanIncident(rows)
+		if err != nil {
+			return nil, err
+		}
+		incidents = append(incidents, inc)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if err := s.loadIncidentAlertIDs(ctx, incidents); err != nil {
+		return nil, err
+	}
+	return incidents, nil
+}
+
+// LinkIncidentAlerts attaches alerts to an incident. Alerts already linked
+// are ignored.
+func (s *BaseStore) LinkIncidentAlerts(ctx context.Context, incidentID int64, alertIDs []int64) error {
+	now := time.Now().UTC()
+	for _, alertID := range uniqueInt64s(alertIDs) {
+		if _, err := s.execContext(ctx, `
+			INSERT INTO incident_alerts (incident_id, alert_id, linked_at) VALUES (?, ?, ?)
+			ON CONFLICT(incident_id, alert_id) DO NOTHING
+		`, incidentID, alertID, now); err != nil {
+			return fmt.Errorf("link alert %d to incident %d: %w", alertID, incidentID, err)
+		}
+	}
+	_, err := s.execContext(ctx, `UPDATE incidents SET updated_at = ? WHERE id = ?`, now, incidentID)
+	return err
+}
+
+// AddIncidentNote stores a note or history entry on an incident.
+func (s *BaseStore) AddIncidentNote(ctx context.Context, n *IncidentNote) error {
+	n.Body = strings.TrimSpace(n.Body)
+	if n.Kind == "" {
+		n.Kind = IncidentNoteKindNote
+	}
+	if n.Body == "" {
+		return fmt.Errorf("body is required")
+	}
+	if len(n.Body) > MaxIncidentNoteLength {
+		return fmt.Errorf("body must be at most %d characters", MaxIncidentNoteLength)
+	}
+	now := time.Now().UTC()
+	id, err := s.insertReturningID(ctx, `
+		INSERT INTO incident_notes (incident_id, kind, body, author, created_at) VALUES (?, ?, ?, ?, ?)
+	`, n.IncidentID, n.Kind, n.Body, n.Author, now)
+	if err != nil {
+		return fmt.Errorf("add incident note: %w", err)
+	}
+	n.ID = id
+	n.CreatedAt = now
+	_, err = s.execContext(ctx, `UPDATE incidents SET updated_at = ? WHERE id = ?`, now, n.IncidentID)
+	return err
+}
+
+// ListIncidentNotes returns an incident's notes, oldest first.
+func (s *BaseStore) ListIncidentNotes(ctx context.Context, incidentID int64) ([]*IncidentNote, error) {
+	rows, err := s.queryContext(ctx, `
+		SELECT id, incident_id, kind, body, author, created_at
+		FROM incident_notes WHERE incident_id = ?
+		ORDER BY created_at ASC, id ASC
+	`, incidentID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var notes []*IncidentNote
+	for rows.Next() {
+		var n IncidentNote
+		if err := rows.Scan(&n.ID, &n.IncidentID, &n.Kind, &n.Body, &n.Author, &n.CreatedAt); err != nil {
+			return nil, err
+		}
+		notes = append(notes, &n)
+	}
+	return notes, rows.Err()
+}
+
+// loadIncidentAlertIDs fills AlertIDs for each incident.
+func (s *BaseStore) loadIncidentAlertIDs(ctx context.Context, incidents []*Incident) error {
+	if len(incidents) == 0 {
+		return nil
+	}
+	byID := make(map[int64]*Incident, len(incidents))
+	args := make([]interface{}, 0, len(incidents))
+	for _, inc := range incidents {
+		inc.AlertIDs = []int64{}
+		byID[inc.ID] = inc
+		args = append(args, inc.ID)
+	}
+	rows, err := s.queryContext(ctx, `
+		SELECT incident_id, alert_id FROM incident_alerts
+		WHERE incident_id IN (`+placeholderList(len(args))+`)
+		ORDER BY alert_id
+	`, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var incidentID, alertID int64
+		if err := rows.Scan(&incidentID, &alertID); err != nil {
+			return err
+		}
+		if inc := byID[incidentID]; inc != nil {
+			inc.AlertIDs = append(inc.AlertIDs, alertID)
+		}
+	}
+	return rows.Err()
+}
+
+func scanIncident(row interface{ Scan(...interface{}) error }) (*Incident, error) {
+	var inc Incident
+	var tenantID, description, assignee, serial, agentID, key, createdBy sql.NullString
+	var resolvedAt sql.NullTime
+	if err := row.Scan(
+		&inc.ID, &tenantID, &inc.Title, &description, &inc.Severity, &inc.Status, &assignee,
+		&serial, &agentID, &inc.Source, &key, &createdBy, &inc.OpenedAt, &inc.UpdatedAt, &resolvedAt,
+	); err != nil {
+		return nil, err
+	}
+	inc.TenantID = tenantID.String
+	inc.Description = description.String
+	inc.Assignee = assignee.String
+	inc.DeviceSerial = serial.String
+	inc.AgentID = agentID.String
+	inc.Key = key.String
+	inc.CreatedBy = createdBy.String
+	if resolvedAt.Valid {
+		t := resolvedAt.Time
+		inc.ResolvedAt = &t
+	}
+	return &inc, nil
+}
+
+func uniqueInt64s(ids []int64) []int64 {
+	seen := make(map[int64]bool, len(ids))
+	out := make([]int64, 0, len(ids))
+	for _, id := range ids {
+		if id > 0 && !seen[id] {
+			seen[id] = true
+			out = append(out, id)
+		}
+	}
+	return out
+}
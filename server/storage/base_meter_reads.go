@@ -0,0 +1,275 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// ============================================================
+// Meter Read Storage Methods (BaseStore)
+// ============================================================
+
+// AddMeterRead stores a certified meter read. Reads are never updated; adding
+// a read whose ID already exists returns ErrMeterReadExists.
+func (s *BaseStore) AddMeterRead(ctx context.Context, read *MeterRead) error {
+	if read == nil || read.ID == "" || read.Serial == "" {
+		return fmt.Errorf("meter read id and serial are required")
+	}
+	if read.ReceivedAt.IsZero() {
+		read.ReceivedAt = time.Now().UTC()
+	}
+	confirmation, err := json.Marshal(read.Confirmation)
+	if err != nil {
+		return fmt.Errorf("failed to encode meter read confirmation: %w", err)
+	}
+	rawOIDs, err := json.Marshal(read.RawOIDs)
+	if err != nil {
+		return fmt.Errorf("failed to encode meter read OIDs: %w", err)
+	}
+
+	result, err := s.execContext(ctx, `
+		INSERT INTO meter_reads (id, serial, agent_id, tenant_id, ip, manufacturer, model, period, trigger,
+			read_at, page_count, mono_pages, color_pages, scan_count, confirmation, raw_oids,
+			signature_alg, key_id, signature, received_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT(id) DO NOTHING
+	`, read.ID, read.Serial, read.AgentID, nullString(read.TenantID), read.IP, read.Manufacturer, read.Model,
+		read.Period, read.Trigger, read.ReadAt.UTC(), read.PageCount, read.MonoPages, read.ColorPages,
+		read.ScanCount, string(confirmation), string(rawOIDs), read.SignatureAlg, nullString(read.KeyID), read.Signature, read.ReceivedAt)
+	if err != nil {
+		return err
+	}
+	if n, err := result.RowsAffected(); err == nil && n == 0 {
+		return ErrMeterReadExists
+	}
+	return nil
+}
+
+// ListMeterReads returns meter reads matching filter, newest first.
+func (s *BaseStore) ListMeterReads(ctx context.Context, filter MeterReadFilter) ([]*MeterRead, error) {
+	var where []string
+	var args []interface{}
+	if filter.Serial != "" {
+		where = append(where, "serial = ?")
+		args = append(args, filter.Serial)
+	}
+	if filter.Period != "" {
+		where = append(where, "period = ?")
+		args = append(args, filter.Period)
+	}
+	if !filter.Before.IsZero() {
+		where = append(where, "read_at < ?")
+		args = append(args, filter.Before.UTC())
+	}
+	if len(filter.TenantIDs) > 0 {
+		where = append(where, "tenant_id IN ("+placeholderList(len(filter.TenantIDs))+")")
+		for _, id := range filter.TenantIDs {
+			args = append(args, id)
+		}
+	}
+
+	query := `
+		SELECT id, serial, agent_id, tenant_id, ip, manufacturer, model, period, trigger,
+		       read_at, page_count, mono_pages, color_pages, scan_count, confirmation, raw_oids,
+		       signature_alg, key_id, signature, received_at
+		FROM meter_reads`
+	if len(where) > 0 {
+		query += " WHERE " + strings.Join(where, " AND ")
+	}
+	query += " ORDER BY read_at DESC"
+	if filter.Limit > 0 {
+		query += " LIMIT ?"
+		args = append(args, filter.Limit)
+	}
+
+	rows, err := s.queryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var reads []*MeterRead
+	for rows.Next() {
+		var r MeterRead
+		var tenantID, ip, manufacturer, model, confirmation, rawOIDs, sigAlg, keyID, sig sql.NullString
+		if err := rows.Scan(&r.ID, &r.Serial, &r.AgentID, &tenantID, &ip, &manufacturer, &model, &r.Period, &r.Trigger,
+			&r.ReadAt, &r.PageCount, &r.MonoPages, &r.ColorPages, &r.ScanCount, &confirmation, &rawOIDs,
+			&sigAlg, &keyID, &sig, &r.ReceivedAt); err != nil {
+			return nil, err
+		}
+		r.TenantID = tenantID.String
+		r.IP = ip.String
+		r.Manufacturer = manufacturer.String
+		r.Model = model.String
+		r.SignatureAlg = sigAlg.String
+		r.KeyID = keyID.String
+		r.Signature = sig.String
+		if confirmation.String != "" {
+			_ = json.Unmarshal([]byte(confirmation.String), &r.Confirmation)
+		}
+		if rawOIDs.String != "" {
+			_ = json.Unmarshal([]byte(rawOIDs.String), &r.RawOIDs)
+		}
+		reads = append(reads, &r)
+	}
+	return reads, rows.Err()
+}
+
+// AddAgentSigningKey registers a meter read signing key for an agent. Adding
+// a key that is already registered is a no-op.
+func (s *BaseStore) AddAgentSigningKey(ctx context.Context, key *AgentSigningKey) error {
+	if key == nil || key.AgentID == "" || key.KeyID == "" || key.PublicKey == "" {
+		return fmt.Errorf("agent id, key id and public key are required")
+	}
+	if key.CreatedAt.IsZero() {
+		key.CreatedAt = time.Now().UTC()
+	}
+	_, err := s.execContext(ctx, `
+		INSERT INTO agent_signing_keys (agent_id, key_id, public_key, created_at)
+		VALUES (?, ?, ?, ?)
+		ON CONFLICT(agent_id, key_id) DO NOTHING
+	`, key.AgentID, key.KeyID, key.PublicKey, key.CreatedAt)
+	return err
+}
+
+// ListAgentSigningKeys returns every signing key registered for an agent,
+// oldest first.
+func (s *BaseStore) ListAgentSigningKeys(ctx context.Context, agentID string) ([]*AgentSigningKey, error) {
+	rows, err := s.queryContext(ctx, `
+		SELECT agent_id, key_id, public_key, created_at FROM agent_signing_keys
+		WHERE agent_id = ? ORDER BY created_at
+	`, agentID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var keys []*AgentSigningKey
+	for rows.Next() {
+		var k AgentSigningKey
+		if err := rows.Scan(&k.AgentID, &k.KeyID, &k.PublicKey, &k.CreatedAt); err != nil {
+			return nil, err
+		}
+		keys = append(keys, &k)
+	}
+	return keys, rows.Err()
+}
+
+// RecordMeterReadAnomaly flags a read for review. Recording the same kind for
+// a read again is a no-op, so reconciliation can be re-run safely without
+// reopening anomalies that were already reviewed.
+func (s *BaseStore) RecordMeterReadAnomaly(ctx context.Context, a *MeterReadAnomaly) error {
+	if a == nil || a.ReadID == "" || a.Kind == "" {
+		return fmt.Errorf("read_id and kind are required")
+	}
+	if a.Status == "" {
+		a.Status = MeterAnomalyOpen
+	}
+	if a.CreatedAt.IsZero() {
+		a.CreatedAt = time.Now().UTC()
+	}
+	_, err := s.execContext(ctx, `
+		INSERT INTO meter_read_anomalies (read_id, serial, tenant_id, period, kind, details, status, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT(read_id, kind) DO NOTHING
+	`, a.ReadID, a.Serial, nullString(a.TenantID), a.Period, a.Kind, a.Details, a.Status, a.CreatedAt)
+	return err
+}
+
+// ListMeterReadAnomalies returns anomalies matching filter, newest first.
+func (s *BaseStore) ListMeterReadAnomalies(ctx context.Context, filter MeterReadAnomalyFilter) ([]*MeterReadAnomaly, error) {
+	var where []string
+	var args []interface{}
+	if filter.ID != 0 {
+		where = append(where, "id = ?")
+		args = append(args, filter.ID)
+	}
+	if filter.Period != "" {
+		where = append(where, "period = ?")
+		args = append(args, filter.Period)
+	}
+	if filter.Status != "" {
+		where = append(where, "status = ?")
+		args = append(args, filter.Status)
+	}
+	if len(filter.TenantIDs) > 0 {
+		where = append(where, "tenant_id IN ("+placeholderList(len(filter.TenantIDs))+")")
+		for _, id := range filter.TenantIDs {
+			args = append(args, id)
+		}
+	}
+	if len(filter.ReadIDs) > 0 {
+		where = append(where, "read_id IN ("+placeholderList(len(filter.ReadIDs))+")")
+		for _, id := range filter.ReadIDs {
+			args = append(args, id)
+		}
+	}
+
+	query := `
+		SELECT id, read_id, serial, tenant_id, period, kind, details, status,
+		       adjusted_mono, adjusted_color, note, resolved_by, resolved_at, created_at
+		FROM meter_read_anomalies`
+	if len(where) > 0 {
+		query += " WHERE " + strings.Join(where, " AND ")
+	}
+	query += " ORDER BY created_at DESC, id DESC"
+
+	rows, err := s.queryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var anomalies []*MeterReadAnomaly
+	for rows.Next() {
+		var a MeterReadAnomaly
+		var tenantID, details, note, resolvedBy sql.NullString
+		var resolvedAt sql.NullTime
+		if err := rows.Scan(&a.ID, &a.ReadID, &a.Serial, &tenantID, &a.Period, &a.Kind, &details, &a.Status,
+			&a.AdjustedMono, &a.AdjustedColor, &note, &resolvedBy, &resolvedAt, &a.CreatedAt); err != nil {
+			return nil, err
+		}
+		a.TenantID = tenantID.String
+		a.Details = details.String
+		a.Note = note.String
+		a.ResolvedBy = resolvedBy.String
+		if resolvedAt.Valid {
+			a.ResolvedAt = resolvedAt.Time
+		}
+		anomalies = append(anomalies, &a)
+	}
+	return anomalies, rows.Err()
+}
+
+// ResolveMeterReadAnomaly records the review outcome of an anomaly. Status
+// must be MeterAnomalyAccepted or MeterAnomalyAdjusted.
+func (s *BaseStore) ResolveMeterReadAnomaly(ctx context.Context, a *MeterReadAnomaly) error {
+	if a == nil || a.ID == 0 {
+		return ErrMeterAnomalyNotFound
+	}
+	if a.Status != MeterAnomalyAccepted && a.Status != MeterAnomalyAdjusted {
+		return fmt.Errorf("invalid anomaly status %q", a.Status)
+	}
+	if a.Status == MeterAnomalyAccepted {
+		a.AdjustedMono, a.AdjustedColor = 0, 0
+	}
+	if a.ResolvedAt.IsZero() {
+		a.ResolvedAt = time.Now().UTC()
+	}
+	result, err := s.execContext(ctx, `
+		UPDATE meter_read_anomalies
+		SET status = ?, adjusted_mono = ?, adjusted_color = ?, note = ?, resolved_by = ?, resolved_at = ?
+		WHERE id = ?
+	`, a.Status, a.AdjustedMono, a.AdjustedColor, a.Note, a.ResolvedBy, a.ResolvedAt, a.ID)
+	if err != nil {
+		return err
+	}
+	if n, err := result.RowsAffected(); err == nil && n == 0 {
+		return ErrMeterAnomalyNotFound
+	}
+	return nil
+}
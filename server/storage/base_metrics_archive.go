@@ -0,0 +1,256 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// GetOldestMetricsTimestamp returns the timestamp of the oldest metrics row,
+// or the zero time when there are none.
+func (s *BaseStore) GetOldestMetricsTimestamp(ctx context.Context) (time.Time, error) {
+	// ORDER BY rather than MIN(): SQLite returns aggregates as text, which
+	// does not scan into a time.
+	var oldest time.Time
+	err := s.queryRowContext(ctx, `SELECT timestamp FROM metrics_history ORDER BY timestamp LIMIT 1`).Scan(&oldest)
+	if err == sql.ErrNoRows {
+		return time.Time{}, nil
+	}
+	return oldest, err
+}
+
+// ListMetricsInRange returns every metrics row with start <= timestamp < end,
+// ordered by serial and timestamp.
+func (s *BaseStore) ListMetricsInRange(ctx context.Context, start, end time.Time) ([]*MetricsSnapshot, error) {
+	rows, err := s.queryContext(ctx, `
+		SELECT serial, agent_id, timestamp, page_count, color_pages, mono_pages, scan_count,
+			COALESCE(duplex_sheets, 0), COALESCE(simplex_sheets, 0), COALESCE(nup_sheets, 0), toner_levels
+		FROM metrics_history
+		WHERE timestamp >= ? AND timestamp < ?
+		ORDER BY serial, timestamp
+	`, start.UTC(), end.UTC())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var metrics []*MetricsSnapshot
+	for rows.Next() {
+		var m MetricsSnapshot
+		var tonerJSON sql.NullString
+		if err := rows.Scan(&m.Serial, &m.AgentID, &m.Timestamp,
+			&m.PageCount, &m.ColorPages, &m.MonoPages, &m.ScanCount,
+			&m.DuplexSheets, &m.SimplexSheets, &m.NUpSheets, &tonerJSON); err != nil {
+			return nil, err
+		}
+		if tonerJSON.Valid {
+			json.Unmarshal([]byte(tonerJSON.String), &m.TonerLevels)
+		}
+		metrics = append(metrics, &m)
+	}
+	return metrics, rows.Err()
+}
+
+// ReplaceMetricsInRange atomically deletes the metrics rows with
+// start <= timestamp < end and inserts keep in their place. Returns the
+// number of rows deleted.
+func (s *BaseStore) ReplaceMetricsInRange(ctx context.Context, start, end time.Time, keep []*MetricsSnapshot) (int64, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	res, err := tx.ExecContext(ctx, s.query(`DELETE FROM metrics_history WHERE timestamp >= ? AND timestamp < ?`), start.UTC(), end.UTC())
+	if err != nil {
+		return 0, err
+	}
+	removed, err := res.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+	insert := s.query(`
+		INSERT INTO metrics_history (serial, agent_id, timestamp, page_count, color_pages, mono_pages, scan_count,
+			duplex_sheets, simplex_sheets, nup_sheets, toner_levels)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`)
+	for _, m := range keep {
+		if m.Timestamp.Before(start) || !m.Timestamp.Before(end) {
+			return 0, fmt.Errorf("metrics row %s at %s outside range", m.Serial, m.Timestamp)
+		}
+		tonerJSON, _ := json.Marshal(m.TonerLevels)
+		if _, err := tx.ExecContext(ctx, insert, m.Serial, m.AgentID, m.Timestamp.UTC(),
+			m.PageCount, m.ColorPages, m.MonoPages, m.ScanCount,
+			m.DuplexSheets, m.SimplexSheets, m.NUpSheets, string(tonerJSON)); err != nil {
+			return 0, err
+		}
+	}
+	return removed, tx.Commit()
+}
+
+// InsertMissingMetrics inserts the rows that have no row with the same serial
+// and timestamp yet. Rows for devices or agents that no longer exist are
+// skipped. Returns how many were inserted and skipped.
+func (s *BaseStore) InsertMissingMetrics(ctx context.Context, metrics []*MetricsSnapshot) (inserted, skipped int, err error) {
+	if len(metrics) == 0 {
+		return 0, 0, nil
+	}
+	start, end := metrics[0].Timestamp, metrics[0].Timestamp
+	for _, m := range metrics {
+		if m.Timestamp.Before(start) {
+			start = m.Timestamp
+		}
+		if m.Timestamp.After(end) {
+			end = m.Timestamp
+		}
+	}
+	existing, err := s.ListMetricsInRange(ctx, start, end.Add(time.Millisecond))
+	if err != nil {
+		return 0, 0, err
+	}
+	seen := make(map[string]bool, len(existing))
+	for _, m := range existing {
+		seen[MetricsRowKey(m.Serial, m.Timestamp)] = true
+	}
+	devices, err := s.existingKeys(ctx, `SELECT serial FROM devices`)
+	if err != nil {
+		return 0, 0, err
+	}
+	agents, err := s.existingKeys(ctx, `SELECT agent_id FROM agents`)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer tx.Rollback()
+	insert := s.query(`
+		INSERT INTO metrics_history (serial, agent_id, timestamp, page_count, color_pages, mono_pages, scan_count,
+			duplex_sheets, simplex_sheets, nup_sheets, toner_levels)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`)
+	for _, m := range metrics {
+		key := MetricsRowKey(m.Serial, m.Timestamp)
+		if seen[key] {
+			continue
+		}
+		if !devices[m.Serial] || !agents[m.AgentID] {
+			skipped++
+			continue
+		}
+		tonerJSON, _ := json.Marshal(m.TonerLevels)
+		if _, err := tx.ExecContext(ctx, insert, m.Serial, m.AgentID, m.Timestamp.UTC(),
+			m.PageCount, m.ColorPages, m.MonoPages, m.ScanCount,
+			m.DuplexSheets, m.SimplexSheets, m.NUpSheets, string(tonerJSON)); err != nil {
+			return 0, 0, err
+		}
+		seen[key] = true
+		inserted++
+	}
+	return inserted, skipped, tx.Commit()
+}
+
+// MetricsRowKey identifies a metrics row by device and time, to millisecond
+// precision (what the archive keeps).
+func MetricsRowKey(serial string, ts time.Time) string {
+	return fmt.Sprintf("%s|%d", serial, ts.UnixMilli())
+}
+
+func (s *BaseStore) existingKeys(ctx context.Context, query string) (map[string]bool, error) {
+	rows, err := s.queryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	keys := map[string]bool{}
+	for rows.Next() {
+		var k string
+		if err := rows.Scan(&k); err != nil {
+			return nil, err
+		}
+		keys[k] = true
+	}
+	return keys, rows.Err()
+}
+
+const metricsArchiveColumns = `id, period_start, period_end, path, row_count, summary_rows, device_count,
+	size_bytes, sha256, created_at, restored_at, restored_until`
+
+// CreateMetricsArchive records an archive file and sets its ID.
+func (s *BaseStore) CreateMetricsArchive(ctx context.Context, a *MetricsArchive) error {
+	if a.CreatedAt.IsZero() {
+		a.CreatedAt = time.Now().UTC()
+	}
+	id, err := s.insertReturningID(ctx, `
+		INSERT INTO metrics_archives (period_start, period_end, path, row_count, summary_rows, device_count, size_bytes, sha256, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`, a.PeriodStart.UTC(), a.PeriodEnd.UTC(), a.Path, a.RowCount, a.SummaryRows, a.DeviceCount, a.SizeBytes, a.SHA256, a.CreatedAt.UTC())
+	if err != nil {
+		return err
+	}
+	a.ID = id
+	return nil
+}
+
+// UpdateMetricsArchive saves the summary row count and restore state.
+func (s *BaseStore) UpdateMetricsArchive(ctx context.Context, a *MetricsArchive) error {
+	_, err := s.execContext(ctx, `
+		UPDATE metrics_archives SET summary_rows = ?, restored_at = ?, restored_until = ? WHERE id = ?
+	`, a.SummaryRows, nullTimePtr(a.RestoredAt), nullTimePtr(a.RestoredUntil), a.ID)
+	return err
+}
+
+// GetMetricsArchive returns an archive by ID, or nil if it does not exist.
+func (s *BaseStore) GetMetricsArchive(ctx context.Context, id int64) (*MetricsArchive, error) {
+	a, err := scanMetricsArchive(s.queryRowContext(ctx, `SELECT `+metricsArchiveColumns+` FROM metrics_archives WHERE id = ?`, id))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return a, err
+}
+
+// ListMetricsArchives returns all archives, oldest period first.
+func (s *BaseStore) ListMetricsArchives(ctx context.Context) ([]*MetricsArchive, error) {
+	rows, err := s.queryContext(ctx, `SELECT `+metricsArchiveColumns+` FROM metrics_archives ORDER BY period_start, id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var archives []*MetricsArchive
+	for rows.Next() {
+		a, err := scanMetricsArchive(rows)
+		if err != nil {
+			return nil, err
+		}
+		archives = append(archives, a)
+	}
+	return archives, rows.Err()
+}
+
+// DeleteMetricsArchive removes an archive record; the file is left alone.
+func (s *BaseStore) DeleteMetricsArchive(ctx context.Context, id int64) error {
+	_, err := s.execContext(ctx, `DELETE FROM metrics_archives WHERE id = ?`, id)
+	return err
+}
+
+func scanMetricsArchive(row interface{ Scan(...interface{}) error }) (*MetricsArchive, error) {
+	var a MetricsArchive
+	var restoredAt, restoredUntil sql.NullTime
+	if err := row.Scan(&a.ID, &a.PeriodStart, &a.PeriodEnd, &a.Path, &a.RowCount, &a.SummaryRows, &a.DeviceCount,
+		&a.SizeBytes, &a.SHA256, &a.CreatedAt, &restoredAt, &restoredUntil); err != nil {
+		return nil, err
+	}
+	if restoredAt.Valid {
+		t := restoredAt.Time
+		a.RestoredAt = &t
+	}
+	if restoredUntil.Valid {
+		t := restoredUntil.Time
+		a.RestoredUntil = &t
+	}
+	return &a, nil
+}
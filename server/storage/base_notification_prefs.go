@@ -0,0 +1,173 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// ============================================================
+// User Notification Preference Storage Methods (BaseStore)
+// ============================================================
+
+const userNotificationPrefsColumns = `user_id, enabled, min_severity, alert_types, email_enabled,
+	email_address, webhook_url, frequency, digest_hour, last_alert_id, last_digest_at, updated_at`
+
+// GetUserNotificationPrefs returns a user's notification preferences, or nil
+// if they haven't saved any.
+func (s *BaseStore) GetUserNotificationPrefs(ctx context.Context, userID int64) (*UserNotificationPrefs, error) {
+	row := s.queryRowContext(ctx, `SELECT `+userNotificationPrefsColumns+`
+		FROM user_notification_prefs WHERE user_id = ?`, userID)
+	p, err := scanUserNotificationPrefs(row)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return p, err
+}
+
+// UpsertUserNotificationPrefs stores a user's preferences. When notifications
+// are switched on, delivery starts from the newest existing alert so the user
+// isn't sent the backlog.
+func (s *BaseStore) UpsertUserNotificationPrefs(ctx context.Context, p *UserNotificationPrefs) error {
+	if err := p.Validate(); err != nil {
+		return err
+	}
+	existing, err := s.GetUserNotificationPrefs(ctx, p.UserID)
+	if err != nil {
+		return err
+	}
+	p.LastAlertID, p.LastDigestAt = 0, nil
+	if existing != nil {
+		p.LastAlertID, p.LastDigestAt = existing.LastAlertID, existing.LastDigestAt
+	}
+	if p.Enabled && (existing == nil || !existing.Enabled) {
+		var maxID sql.NullInt64
+		if err := s.queryRowContext(ctx, `SELECT MAX(id) FROM alerts`).Scan(&maxID); err != nil {
+			return fmt.Errorf("find latest alert: %w", err)
+		}
+		p.LastAlertID = maxID.Int64
+	}
+
+	types, _ := json.Marshal(p.AlertTypes)
+	p.UpdatedAt = time.Now().UTC()
+	_, err = s.execContext(ctx, `
+		INSERT INTO user_notification_prefs (
+			user_id, enabled, min_severity, alert_types, email_enabled, email_address,
+			webhook_url, frequency, digest_hour, last_alert_id, last_digest_at, updated_at
+		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT(user_id) DO UPDATE SET
+			enabled = excluded.enabled,
+			min_severity = excluded.min_severity,
+			alert_types = excluded.alert_types,
+			email_enabled = excluded.email_enabled,
+			email_address = excluded.email_address,
+			webhook_url = excluded.webhook_url,
+			frequency = excluded.frequency,
+			digest_hour = excluded.digest_hour,
+			last_alert_id = excluded.last_alert_id,
+			updated_at = excluded.updated_at
+	`,
+		p.UserID, p.Enabled, p.MinSeverity, string(types), p.EmailEnabled, nullString(p.EmailAddress),
+		nullString(p.WebhookURL), p.Frequency, p.DigestHour, p.LastAlertID, nullTimePtr(p.LastDigestAt), p.UpdatedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("upsert user notification prefs: %w", err)
+	}
+	return nil
+}
+
+// ListEnabledUserNotificationPrefs returns the preferences of every user who
+// has notifications switched on.
+func (s *BaseStore) ListEnabledUserNotificationPrefs(ctx context.Context) ([]*UserNotificationPrefs, error) {
+	rows, err := s.queryContext(ctx, `SELECT `+userNotificationPrefsColumns+`
+		FROM user_notification_prefs WHERE enabled = ? ORDER BY user_id`, true)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var prefs []*UserNotificationPrefs
+	for rows.Next() {
+		p, err := scanUserNotificationPrefs(rows)
+		if err != nil {
+			return nil, err
+		}
+		prefs = append(prefs, p)
+	}
+	return prefs, rows.Err()
+}
+
+// SetUserNotificationProgress records the newest alert handled for a user
+// and, when digestAt is set, when their last digest went out.
+func (s *BaseStore) SetUserNotificationProgress(ctx context.Context, userID, lastAlertID int64, digestAt *time.Time) error {
+	if digestAt != nil {
+		_, err := s.execContext(ctx, `UPDATE user_notification_prefs SET last_alert_id = ?, last_digest_at = ? WHERE user_id = ?`,
+			lastAlertID, digestAt.UTC(), userID)
+		return err
+	}
+	_, err := s.execContext(ctx, `UPDATE user_notification_prefs SET last_alert_id = ? WHERE user_id = ?`, lastAlertID, userID)
+	return err
+}
+
+// ListAlertsAfter returns up to limit alerts with IDs above afterID, oldest
+// first, so callers can follow new alerts without missing any.
+func (s *BaseStore) ListAlertsAfter(ctx context.Context, afterID int64, limit int) ([]*Alert, error) {
+	if limit <= 0 {
+		limit = 500
+	}
+	rows, err := s.queryContext(ctx, `
+		SELECT
+			id, rule_id, type, severity, scope, status,
+			tenant_id, site_id, agent_id, device_serial,
+			title, message, details,
+			triggered_at, acknowledged_at, acknowledged_by, resolved_at,
+			suppressed_until, expires_at,
+			escalation_level, last_escalated_at,
+			state_change_count, is_flapping,
+			parent_alert_id, child_count,
+			notifications_sent, last_notified_at,
+			created_at, updated_at
+		FROM alerts
+		WHERE id > ?
+		ORDER BY id
+		LIMIT ?
+	`, afterID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("list alerts after %d: %w", afterID, err)
+	}
+	defer rows.Close()
+
+	alerts, err := s.scanAlerts(rows)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]*Alert, len(alerts))
+	for i := range alerts {
+		result[i] = &alerts[i]
+	}
+	return result, nil
+}
+
+func scanUserNotificationPrefs(row interface{ Scan(...interface{}) error }) (*UserNotificationPrefs, error) {
+	var p UserNotificationPrefs
+	var types, email, webhook sql.NullString
+	var lastDigest sql.NullTime
+	if err := row.Scan(
+		&p.UserID, &p.Enabled, &p.MinSeverity, &types, &p.EmailEnabled,
+		&email, &webhook, &p.Frequency, &p.DigestHour, &p.LastAlertID, &lastDigest, &p.UpdatedAt,
+	); err != nil {
+		return nil, err
+	}
+	if types.String != "" {
+		_ = json.Unmarshal([]byte(types.String), &p.AlertTypes)
+	}
+	p.EmailAddress = email.String
+	p.WebhookURL = webhook.String
+	if lastDigest.Valid {
+		t := lastDigest.Time
+		p.LastDigestAt = &t
+	}
+	return &p, nil
+}
@@ -0,0 +1,117 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// ============================================================
+// Notification Template Storage Methods (BaseStore)
+// ============================================================
+
+const notificationTemplateColumns = `id, tenant_id, channel_type, subject, text_body, html_body,
+	updated_by, created_at, updated_at`
+
+// UpsertNotificationTemplate stores the template for its tenant and channel
+// type, replacing any existing one.
+func (s *BaseStore) UpsertNotificationTemplate(ctx context.Context, t *NotificationTemplate) error {
+	if err := t.Validate(); err != nil {
+		return err
+	}
+	now := time.Now().UTC()
+	_, err := s.execContext(ctx, `
+		INSERT INTO notification_templates (
+			tenant_id, channel_type, subject, text_body, html_body, updated_by, created_at, updated_at
+		) VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT(tenant_id, channel_type) DO UPDATE SET
+			subject = excluded.subject,
+			text_body = excluded.text_body,
+			html_body = excluded.html_body,
+			updated_by = excluded.updated_by,
+			updated_at = excluded.updated_at
+	`,
+		t.TenantID, t.ChannelType, nullString(t.Subject), t.TextBody, nullString(t.HTMLBody),
+		nullString(t.UpdatedBy), now, now,
+	)
+	if err != nil {
+		return fmt.Errorf("upsert notification template: %w", err)
+	}
+	row := s.queryRowContext(ctx, `SELECT `+notificationTemplateColumns+`
+		FROM notification_templates WHERE tenant_id = ? AND channel_type = ?`, t.TenantID, t.ChannelType)
+	stored, err := scanNotificationTemplate(row)
+	if err != nil {
+		return err
+	}
+	*t = *stored
+	return nil
+}
+
+// GetNotificationTemplate returns a template by ID, or nil if it doesn't
+// exist.
+func (s *BaseStore) GetNotificationTemplate(ctx context.Context, id int64) (*NotificationTemplate, error) {
+	row := s.queryRowContext(ctx, `SELECT `+notificationTemplateColumns+` FROM notification_templates WHERE id = ?`, id)
+	t, err := scanNotificationTemplate(row)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return t, err
+}
+
+// ListNotificationTemplates returns all templates, global defaults first.
+func (s *BaseStore) ListNotificationTemplates(ctx context.Context) ([]*NotificationTemplate, error) {
+	rows, err := s.queryContext(ctx, `SELECT `+notificationTemplateColumns+`
+		FROM notification_templates ORDER BY tenant_id, channel_type`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var templates []*NotificationTemplate
+	for rows.Next() {
+		t, err := scanNotificationTemplate(rows)
+		if err != nil {
+			return nil, err
+		}
+		templates = append(templates, t)
+	}
+	return templates, rows.Err()
+}
+
+// ResolveNotificationTemplate returns the template that applies to a tenant's
+// notifications of channelType: the tenant's override, else the global
+// default, else nil.
+func (s *BaseStore) ResolveNotificationTemplate(ctx context.Context, tenantID, channelType string) (*NotificationTemplate, error) {
+	row := s.queryRowContext(ctx, `SELECT `+notificationTemplateColumns+`
+		FROM notification_templates
+		WHERE channel_type = ? AND (tenant_id = ? OR tenant_id = '')
+		ORDER BY tenant_id DESC LIMIT 1`, channelType, tenantID)
+	t, err := scanNotificationTemplate(row)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return t, err
+}
+
+// DeleteNotificationTemplate removes a template. Notifications fall back to
+// the global default or the built-in template.
+func (s *BaseStore) DeleteNotificationTemplate(ctx context.Context, id int64) error {
+	_, err := s.execContext(ctx, `DELETE FROM notification_templates WHERE id = ?`, id)
+	return err
+}
+
+func scanNotificationTemplate(row interface{ Scan(...interface{}) error }) (*NotificationTemplate, error) {
+	var t NotificationTemplate
+	var subject, htmlBody, updatedBy sql.NullString
+	if err := row.Scan(
+		&t.ID, &t.TenantID, &t.ChannelType, &subject, &t.TextBody, &htmlBody,
+		&updatedBy, &t.CreatedAt, &t.UpdatedAt,
+	); err != nil {
+		return nil, err
+	}
+	t.Subject = subject.String
+	t.HTMLBody = htmlBody.String
+	t.UpdatedBy = updatedBy.String
+	return &t, nil
+}
@@ -0,0 +1,168 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// ============================================================
+// Parse Sample Storage Methods (BaseStore)
+// ============================================================
+
+const parseSampleColumns = `id, agent_id, tenant_id, ip, serial, manufacturer, model, sys_object_id,
+	missing_json, steps_json, pdu_count, captured_at, received_at`
+
+// SaveParseSample stores a parse-debug sample, replacing the previous sample
+// from the same agent for the same IP.
+func (s *BaseStore) SaveParseSample(ctx context.Context, p *ParseSample) error {
+	if p == nil || p.AgentID == "" || p.IP == "" {
+		return fmt.Errorf("agent_id and ip are required")
+	}
+	missing, _ := json.Marshal(p.Missing)
+	steps, _ := json.Marshal(p.Steps)
+	pdus := string(p.RawPDUs)
+	if pdus == "" {
+		pdus = "[]"
+	}
+	now := time.Now().UTC()
+	if p.CapturedAt.IsZero() {
+		p.CapturedAt = now
+	}
+	_, err := s.execContext(ctx, `
+		INSERT INTO parse_samples (
+			agent_id, tenant_id, ip, serial, manufacturer, model, sys_object_id,
+			missing_json, steps_json, raw_pdus_json, pdu_count, captured_at, received_at
+		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT(agent_id, ip) DO UPDATE SET
+			tenant_id = excluded.tenant_id,
+			serial = excluded.serial,
+			manufacturer = excluded.manufacturer,
+			model = excluded.model,
+			sys_object_id = excluded.sys_object_id,
+			missing_json = excluded.missing_json,
+			steps_json = excluded.steps_json,
+			raw_pdus_json = excluded.raw_pdus_json,
+			pdu_count = excluded.pdu_count,
+			captured_at = excluded.captured_at,
+			received_at = excluded.received_at
+	`, p.AgentID, nullString(p.TenantID), p.IP, nullString(p.Serial), nullString(p.Manufacturer),
+		nullString(p.Model), nullString(p.SysObjectID), string(missing), string(steps), pdus,
+		p.PDUCount, p.CapturedAt.UTC(), now)
+	if err != nil {
+		return fmt.Errorf("save parse sample: %w", err)
+	}
+	p.ReceivedAt = now
+	return nil
+}
+
+// GetParseSample returns a sample with its raw PDUs, or nil if it doesn't
+// exist.
+func (s *BaseStore) GetParseSample(ctx context.Context, id int64) (*ParseSample, error) {
+	row := s.queryRowContext(ctx, `SELECT `+parseSampleColumns+`, raw_pdus_json FROM parse_samples WHERE id = ?`, id)
+	p, err := scanParseSample(row, true)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return p, err
+}
+
+// ListParseSamples returns samples matching filter, most recent first.
+func (s *BaseStore) ListParseSamples(ctx context.Context, filter ParseSampleFilter) ([]*ParseSample, error) {
+	var where []string
+	var args []interface{}
+	if len(filter.TenantIDs) > 0 {
+		where = append(where, "tenant_id IN ("+placeholderList(len(filter.TenantIDs))+")")
+		for _, id := range filter.TenantIDs {
+			args = append(args, id)
+		}
+	}
+	if filter.AgentID != "" {
+		where = append(where, "agent_id = ?")
+		args = append(args, filter.AgentID)
+	}
+	if filter.Manufacturer != "" {
+		where = append(where, "LOWER(COALESCE(manufacturer, '')) = ?")
+		args = append(args, strings.ToLower(filter.Manufacturer))
+	}
+	if filter.SysObjectID != "" {
+		where = append(where, "sys_object_id = ?")
+		args = append(args, filter.SysObjectID)
+	}
+	if filter.Missing != "" {
+		where = append(where, "missing_json LIKE ?")
+		args = append(args, `%"`+filter.Missing+`"%`)
+	}
+
+	columns := parseSampleColumns
+	if filter.WithPDUs {
+		columns += ", raw_pdus_json"
+	}
+	query := `SELECT ` + columns + ` FROM parse_samples`
+	if len(where) > 0 {
+		query += " WHERE " + strings.Join(where, " AND ")
+	}
+	query += " ORDER BY captured_at DESC, id DESC"
+	if filter.Limit > 0 {
+		query += " LIMIT ?"
+		args = append(args, filter.Limit)
+	}
+
+	rows, err := s.queryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var samples []*ParseSample
+	for rows.Next() {
+		p, err := scanParseSample(rows, filter.WithPDUs)
+		if err != nil {
+			return nil, err
+		}
+		samples = append(samples, p)
+	}
+	return samples, rows.Err()
+}
+
+// DeleteParseSamplesBefore removes samples captured before cutoff.
+func (s *BaseStore) DeleteParseSamplesBefore(ctx context.Context, cutoff time.Time) (int64, error) {
+	res, err := s.execContext(ctx, `DELETE FROM parse_samples WHERE captured_at < ?`, cutoff.UTC())
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+func scanParseSample(row interface{ Scan(...interface{}) error }, withPDUs bool) (*ParseSample, error) {
+	var p ParseSample
+	var tenantID, serial, manufacturer, model, sysObjectID, missing, steps, pdus sql.NullString
+	dest := []interface{}{
+		&p.ID, &p.AgentID, &tenantID, &p.IP, &serial, &manufacturer, &model, &sysObjectID,
+		&missing, &steps, &p.PDUCount, &p.CapturedAt, &p.ReceivedAt,
+	}
+	if withPDUs {
+		dest = append(dest, &pdus)
+	}
+	if err := row.Scan(dest...); err != nil {
+		return nil, err
+	}
+	p.TenantID = tenantID.String
+	p.Serial = serial.String
+	p.Manufacturer = manufacturer.String
+	p.Model = model.String
+	p.SysObjectID = sysObjectID.String
+	if missing.String != "" {
+		_ = json.Unmarshal([]byte(missing.String), &p.Missing)
+	}
+	if steps.String != "" {
+		_ = json.Unmarshal([]byte(steps.String), &p.Steps)
+	}
+	if pdus.String != "" {
+		p.RawPDUs = json.RawMessage(pdus.String)
+	}
+	return &p, nil
+}
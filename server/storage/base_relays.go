@@ -0,0 +1,181 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// ============================================================
+// Relay Storage Methods (BaseStore)
+// ============================================================
+
+const relayColumns = `id, name, region, url, token_hash, version, last_seen,
+	queue_depth, forwarded_total, dropped_total, created_by, created_at`
+
+// CreateRelay registers a relay and returns its raw token. Only the hash is
+// kept.
+func (s *BaseStore) CreateRelay(ctx context.Context, relay *Relay) (string, error) {
+	if err := relay.Validate(); err != nil {
+		return "", err
+	}
+	rawToken, err := generateSecureToken(32)
+	if err != nil {
+		return "", fmt.Errorf("failed to generate relay token: %w", err)
+	}
+	now := time.Now().UTC()
+	id, err := s.insertReturningID(ctx, `
+		INSERT INTO relays (name, region, url, token_hash, created_by, created_at)
+		VALUES (?, ?, ?, ?, ?, ?)
+	`, relay.Name, relay.Region, relay.URL, hashSHA256(rawToken), nullString(relay.CreatedBy), now)
+	if err != nil {
+		return "", fmt.Errorf("create relay: %w", err)
+	}
+	relay.ID = id
+	relay.TokenHash = hashSHA256(rawToken)
+	relay.CreatedAt = now
+	return rawToken, nil
+}
+
+// GetRelay returns a relay by ID, or nil if it doesn't exist.
+func (s *BaseStore) GetRelay(ctx context.Context, id int64) (*Relay, error) {
+	row := s.queryRowContext(ctx, `SELECT `+relayColumns+` FROM relays WHERE id = ?`, id)
+	relay, err := scanRelay(row)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return relay, err
+}
+
+// GetRelayByToken returns the relay for a raw token, or nil if none matches.
+func (s *BaseStore) GetRelayByToken(ctx context.Context, token string) (*Relay, error) {
+	if token == "" {
+		return nil, nil
+	}
+	row := s.queryRowContext(ctx, `SELECT `+relayColumns+` FROM relays WHERE token_hash = ?`, hashSHA256(token))
+	relay, err := scanRelay(row)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return relay, err
+}
+
+// ListRelays returns all relays by name with their assigned agent counts.
+func (s *BaseStore) ListRelays(ctx context.Context) ([]*Relay, error) {
+	rows, err := s.queryContext(ctx, `SELECT `+relayColumns+` FROM relays ORDER BY name`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var relays []*Relay
+	for rows.Next() {
+		relay, err := scanRelay(rows)
+		if err != nil {
+			return nil, err
+		}
+		relays = append(relays, relay)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	counts, err := s.queryContext(ctx, `SELECT relay_id, COUNT(*) FROM relay_agents GROUP BY relay_id`)
+	if err != nil {
+		return nil, err
+	}
+	defer counts.Close()
+	byID := make(map[int64]int)
+	for counts.Next() {
+		var id int64
+		var n int
+		if err := counts.Scan(&id, &n); err != nil {
+			return nil, err
+		}
+		byID[id] = n
+	}
+	for _, relay := range relays {
+		relay.AgentCount = byID[relay.ID]
+	}
+	return relays, counts.Err()
+}
+
+// UpdateRelayHeartbeat records a relay's reported status.
+func (s *BaseStore) UpdateRelayHeartbeat(ctx context.Context, id int64, hb RelayHeartbeat, at time.Time) error {
+	_, err := s.execContext(ctx, `
+		UPDATE relays SET version = ?, last_seen = ?, queue_depth = ?, forwarded_total = ?, dropped_total = ?
+		WHERE id = ?
+	`, nullString(hb.Version), at.UTC(), hb.QueueDepth, hb.ForwardedTotal, hb.DroppedTotal, id)
+	return err
+}
+
+// DeleteRelay removes a relay and its agent assignments. Its token stops
+// working immediately.
+func (s *BaseStore) DeleteRelay(ctx context.Context, id int64) error {
+	res, err := s.execContext(ctx, `DELETE FROM relays WHERE id = ?`, id)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return fmt.Errorf("relay not found")
+	}
+	_, err = s.execContext(ctx, `DELETE FROM relay_agents WHERE relay_id = ?`, id)
+	return err
+}
+
+// SetAgentRelay records the relay an agent uploads through. A relayID of 0
+// means the agent uploads directly.
+func (s *BaseStore) SetAgentRelay(ctx context.Context, agentID string, relayID int64, rttMS int) error {
+	if relayID == 0 {
+		_, err := s.execContext(ctx, `DELETE FROM relay_agents WHERE agent_id = ?`, agentID)
+		return err
+	}
+	now := time.Now().UTC()
+	res, err := s.execContext(ctx, `
+		UPDATE relay_agents SET rtt_ms = ?,
+			assigned_at = CASE WHEN relay_id = ? THEN assigned_at ELSE ? END,
+			relay_id = ?
+		WHERE agent_id = ?
+	`, rttMS, relayID, now, relayID, agentID)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n > 0 {
+		return nil
+	}
+	_, err = s.execContext(ctx, `
+		INSERT INTO relay_agents (agent_id, relay_id, rtt_ms, assigned_at) VALUES (?, ?, ?, ?)
+	`, agentID, relayID, rttMS, now)
+	return err
+}
+
+// GetAgentRelayID returns the relay an agent uploads through, or 0 when it
+// uploads directly.
+func (s *BaseStore) GetAgentRelayID(ctx context.Context, agentID string) (int64, error) {
+	var id int64
+	err := s.queryRowContext(ctx, `SELECT relay_id FROM relay_agents WHERE agent_id = ?`, agentID).Scan(&id)
+	if err == sql.ErrNoRows {
+		return 0, nil
+	}
+	return id, err
+}
+
+func scanRelay(row interface{ Scan(...interface{}) error }) (*Relay, error) {
+	var relay Relay
+	var version, createdBy sql.NullString
+	var lastSeen sql.NullTime
+	if err := row.Scan(
+		&relay.ID, &relay.Name, &relay.Region, &relay.URL, &relay.TokenHash, &version, &lastSeen,
+		&relay.QueueDepth, &relay.ForwardedTotal, &relay.DroppedTotal, &createdBy, &relay.CreatedAt,
+	); err != nil {
+		return nil, err
+	}
+	relay.Version = version.String
+	if lastSeen.Valid {
+		t := lastSeen.Time
+		relay.LastSeen = &t
+	}
+	relay.CreatedBy = createdBy.String
+	return &relay, nil
+}
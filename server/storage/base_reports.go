@@ -509,12 +509,12 @@ func (s *BaseStore) UpdateReportRun(ctx context.Context, run *ReportRun) error {
 		UPDATE report_runs SET
 			status = ?, completed_at = ?, duration_ms = ?,
 			row_count = ?, result_size_bytes = ?, result_path = ?, result_data = ?,
-			error_message = ?
+			error_message = ?, delivered_to = ?, delivered_at = ?, delivery_error = ?
 		WHERE id = ?
 	`,
 		run.Status, run.CompletedAt, run.DurationMS,
 		run.RowCount, run.ResultSize, run.ResultPath, run.ResultData,
-		run.ErrorMessage, run.ID,
+		run.ErrorMessage, strings.Join(run.DeliveredTo, ","), run.DeliveredAt, run.DeliveryError, run.ID,
 	)
 	return err
 }
@@ -525,7 +525,7 @@ func (s *BaseStore) GetReportRun(ctx context.Context, id int64) (*ReportRun, err
 		SELECT id, report_id, schedule_id, status, format,
 			started_at, completed_at, duration_ms,
 			parameters_json, row_count, result_size_bytes, result_path, result_data,
-			error_message, run_by, created_at
+			error_message, run_by, delivered_to, delivered_at, delivery_error, created_at
 		FROM report_runs WHERE id = ?
 	`, id)
 	return s.scanRun(row)
@@ -543,7 +543,7 @@ func (s *BaseStore) ListReportRuns(ctx context.Context, filter ReportRunFilter)
 		SELECT rr.id, rr.report_id, r.name, r.type, rr.schedule_id, rr.status, rr.format,
 			rr.started_at, rr.completed_at, rr.duration_ms,
 			rr.parameters_json, rr.row_count, rr.result_size_bytes, rr.result_path, rr.result_data,
-			rr.error_message, rr.run_by, rr.created_at
+			rr.error_message, rr.run_by, rr.delivered_to, rr.delivered_at, rr.delivery_error, rr.created_at
 		FROM report_runs rr
 		LEFT JOIN reports r ON rr.report_id = r.id
 		WHERE 1=1
@@ -598,12 +598,14 @@ func (s *BaseStore) scanRun(row *sql.Row) (*ReportRun, error) {
 	var completedAt sql.NullTime
 	var durationMS, rowCount, resultSize sql.NullInt64
 	var parametersJSON, resultPath, resultData, errorMessage, runBy sql.NullString
+	var deliveredTo, deliveryError sql.NullString
+	var deliveredAt sql.NullTime
 
 	err := row.Scan(
 		&run.ID, &run.ReportID, &scheduleID, &run.Status, &run.Format,
 		&run.StartedAt, &completedAt, &durationMS,
 		&parametersJSON, &rowCount, &resultSize, &resultPath, &resultData,
-		&errorMessage, &runBy, &run.CreatedAt,
+		&errorMessage, &runBy, &deliveredTo, &deliveredAt, &deliveryError, &run.CreatedAt,
 	)
 	if err == sql.ErrNoRows {
 		return nil, nil
@@ -626,6 +628,13 @@ func (s *BaseStore) scanRun(row *sql.Row) (*ReportRun, error) {
 	run.ResultData = resultData.String
 	run.ErrorMessage = errorMessage.String
 	run.RunBy = runBy.String
+	if deliveredTo.String != "" {
+		run.DeliveredTo = strings.Split(deliveredTo.String, ",")
+	}
+	if deliveredAt.Valid {
+		run.DeliveredAt = &deliveredAt.Time
+	}
+	run.DeliveryError = deliveryError.String
 
 	return &run, nil
 }
@@ -637,12 +646,14 @@ func (s *BaseStore) scanRunRow(rows *sql.Rows) (*ReportRun, error) {
 	var completedAt sql.NullTime
 	var durationMS, rowCount, resultSize sql.NullInt64
 	var parametersJSON, resultPath, resultData, errorMessage, runBy sql.NullString
+	var deliveredTo, deliveryError sql.NullString
+	var deliveredAt sql.NullTime
 
 	err := rows.Scan(
 		&run.ID, &run.ReportID, &reportName, &reportType, &scheduleID, &run.Status, &run.Format,
 		&run.StartedAt, &completedAt, &durationMS,
 		&parametersJSON, &rowCount, &resultSize, &resultPath, &resultData,
-		&errorMessage, &runBy, &run.CreatedAt,
+		&errorMessage, &runBy, &deliveredTo, &deliveredAt, &deliveryError, &run.CreatedAt,
 	)
 	if err != nil {
 		return nil, fmt.Errorf("scan run row: %w", err)
@@ -664,6 +675,13 @@ func (s *BaseStore) scanRunRow(rows *sql.Rows) (*ReportRun, error) {
 	run.ResultData = resultData.String
 	run.ErrorMessage = errorMessage.String
 	run.RunBy = runBy.String
+	if deliveredTo.String != "" {
+		run.DeliveredTo = strings.Split(deliveredTo.String, ",")
+	}
+	if deliveredAt.Valid {
+		run.DeliveredAt = &deliveredAt.Time
+	}
+	run.DeliveryError = deliveryError.String
 
 	return &run, nil
 }
@@ -0,0 +1,125 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"strings"
+	"time"
+)
+
+const scanPathCheckColumns = `id, serial, agent_id, match_method, verified, sender, recipients,
+	subject, source_ip, attachment_count, attachment_bytes, attachments_json, received_at`
+
+// SaveScanPathCheck stores a received test scan and sets its ID.
+func (s *BaseStore) SaveScanPathCheck(ctx context.Context, c *ScanPathCheck) error {
+	if c.ReceivedAt.IsZero() {
+		c.ReceivedAt = time.Now().UTC()
+	}
+	attachments, err := json.Marshal(c.Attachments)
+	if err != nil {
+		return err
+	}
+	id, err := s.insertReturningID(ctx, `
+		INSERT INTO scan_path_checks (serial, agent_id, match_method, verified, sender, recipients,
+			subject, source_ip, attachment_count, attachment_bytes, attachments_json, received_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`, nullString(c.Serial), nullString(c.AgentID), nullString(c.MatchMethod), c.Verified, c.Sender,
+		strings.Join(c.Recipients, ","), c.Subject, c.SourceIP, c.AttachmentCount, c.AttachmentBytes,
+		string(attachments), c.ReceivedAt.UTC())
+	if err != nil {
+		return err
+	}
+	c.ID = id
+	return nil
+}
+
+// ListScanPathChecks returns received test scans, newest first.
+func (s *BaseStore) ListScanPathChecks(ctx context.Context, filter ScanPathCheckFilter) ([]*ScanPathCheck, error) {
+	var where []string
+	var args []interface{}
+	if filter.Serial != "" {
+		where = append(where, "serial = ?")
+		args = append(args, filter.Serial)
+	}
+	if filter.Unmatched {
+		where = append(where, "serial IS NULL")
+	}
+	if len(filter.AgentIDs) > 0 {
+		where = append(where, "agent_id IN ("+placeholderList(len(filter.AgentIDs))+")")
+		for _, id := range filter.AgentIDs {
+			args = append(args, id)
+		}
+	}
+
+	query := `SELECT ` + scanPathCheckColumns + ` FROM scan_path_checks`
+	if len(where) > 0 {
+		query += " WHERE " + strings.Join(where, " AND ")
+	}
+	query += " ORDER BY received_at DESC, id DESC"
+	if filter.Limit > 0 {
+		query += " LIMIT ?"
+		args = append(args, filter.Limit)
+	}
+
+	rows, err := s.queryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var checks []*ScanPathCheck
+	for rows.Next() {
+		c, err := scanScanPathCheck(rows)
+		if err != nil {
+			return nil, err
+		}
+		checks = append(checks, c)
+	}
+	return checks, rows.Err()
+}
+
+// GetLatestScanPathCheck returns the most recent test scan for a device,
+// preferring a verified one so a later bad test does not hide that the path
+// once worked. Returns nil when the device has none.
+func (s *BaseStore) GetLatestScanPathCheck(ctx context.Context, serial string) (*ScanPathCheck, error) {
+	row := s.queryRowContext(ctx, `SELECT `+scanPathCheckColumns+` FROM scan_path_checks
+		WHERE serial = ? ORDER BY verified DESC, received_at DESC, id DESC LIMIT 1`, serial)
+	c, err := scanScanPathCheck(row)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return c, err
+}
+
+// DeleteScanPathChecksBefore prunes test scans received before cutoff.
+func (s *BaseStore) DeleteScanPathChecksBefore(ctx context.Context, cutoff time.Time) (int64, error) {
+	res, err := s.execContext(ctx, `DELETE FROM scan_path_checks WHERE received_at < ?`, cutoff.UTC())
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+func scanScanPathCheck(row interface{ Scan(...interface{}) error }) (*ScanPathCheck, error) {
+	var c ScanPathCheck
+	var serial, agentID, method sql.NullString
+	var recipients, attachments string
+	if err := row.Scan(&c.ID, &serial, &agentID, &method, &c.Verified, &c.Sender, &recipients,
+		&c.Subject, &c.SourceIP, &c.AttachmentCount, &c.AttachmentBytes, &attachments, &c.ReceivedAt); err != nil {
+		return nil, err
+	}
+	c.Serial = serial.String
+	c.AgentID = agentID.String
+	c.MatchMethod = method.String
+	c.Recipients = []string{}
+	if recipients != "" {
+		c.Recipients = strings.Split(recipients, ",")
+	}
+	if attachments != "" {
+		if err := json.Unmarshal([]byte(attachments), &c.Attachments); err != nil {
+			return nil, err
+		}
+	}
+	return &c, nil
+}
@@ -0,0 +1,184 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// ============================================================
+// Share Link Storage Methods (BaseStore)
+// ============================================================
+
+const shareLinkColumns = `id, tenant_id, kind, name, filter_json, report_run_id,
+	token_hash, password_hash, expires_at, revoked_at, revoked_by,
+	access_count, last_accessed_at, created_by, created_at`
+
+// CreateShareLink stores a new share link, optionally protected by password,
+// and returns the raw token. Only its hash is kept.
+func (s *BaseStore) CreateShareLink(ctx context.Context, link *ShareLink, password string) (string, error) {
+	if err := link.Validate(); err != nil {
+		return "", err
+	}
+	filter, err := json.Marshal(link.Filter)
+	if err != nil {
+		return "", err
+	}
+	rawToken, err := generateSecureToken(24)
+	if err != nil {
+		return "", fmt.Errorf("failed to generate share token: %w", err)
+	}
+	// URL-safe base64 may end in padding, which is awkward in a path segment
+	rawToken = strings.TrimRight(rawToken, "=")
+	passwordHash := ""
+	if password != "" {
+		if passwordHash, err = hashArgon(password); err != nil {
+			return "", err
+		}
+	}
+	now := time.Now().UTC()
+	id, err := s.insertReturningID(ctx, `
+		INSERT INTO share_links (
+			tenant_id, kind, name, filter_json, report_run_id,
+			token_hash, password_hash, expires_at, created_by, created_at
+		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`,
+		link.TenantID, link.Kind, link.Name, string(filter), nullInt64Ptr(link.ReportRunID),
+		hashSHA256(rawToken), nullString(passwordHash), link.ExpiresAt.UTC(), nullString(link.CreatedBy), now,
+	)
+	if err != nil {
+		return "", fmt.Errorf("create share link: %w", err)
+	}
+	link.ID = id
+	link.TokenHash = hashSHA256(rawToken)
+	link.PasswordHash = passwordHash
+	link.HasPassword = passwordHash != ""
+	link.CreatedAt = now
+	return rawToken, nil
+}
+
+// GetShareLink returns a share link by ID, or nil if it doesn't exist.
+func (s *BaseStore) GetShareLink(ctx context.Context, id int64) (*ShareLink, error) {
+	row := s.queryRowContext(ctx, `SELECT `+shareLinkColumns+` FROM share_links WHERE id = ?`, id)
+	link, err := scanShareLink(row)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return link, err
+}
+
+// GetShareLinkByToken returns the share link for a raw token, or nil if no
+// link matches. Expired and revoked links are returned; callers check Active.
+func (s *BaseStore) GetShareLinkByToken(ctx context.Context, token string) (*ShareLink, error) {
+	if token == "" {
+		return nil, nil
+	}
+	row := s.queryRowContext(ctx, `SELECT `+shareLinkColumns+` FROM share_links WHERE token_hash = ?`, hashSHA256(token))
+	link, err := scanShareLink(row)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return link, err
+}
+
+// ListShareLinks returns share links, newest first. A non-empty tenantIDs
+// limits the result to those tenants.
+func (s *BaseStore) ListShareLinks(ctx context.Context, tenantIDs []string) ([]*ShareLink, error) {
+	query := `SELECT ` + shareLinkColumns + ` FROM share_links`
+	var args []interface{}
+	if len(tenantIDs) > 0 {
+		placeholders := make([]string, len(tenantIDs))
+		for i, t := range tenantIDs {
+			placeholders[i] = "?"
+			args = append(args, t)
+		}
+		query += fmt.Sprintf(` WHERE tenant_id IN (%s)`, strings.Join(placeholders, ","))
+	}
+	query += ` ORDER BY created_at DESC, id DESC`
+	rows, err := s.queryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var links []*ShareLink
+	for rows.Next() {
+		link, err := scanShareLink(rows)
+		if err != nil {
+			return nil, err
+		}
+		links = append(links, link)
+	}
+	return links, rows.Err()
+}
+
+// RevokeShareLink disables a share link immediately. Revoking an already
+// revoked link keeps the original revocation.
+func (s *BaseStore) RevokeShareLink(ctx context.Context, id int64, revokedBy string) error {
+	res, err := s.execContext(ctx, `
+		UPDATE share_links SET revoked_at = ?, revoked_by = ?
+		WHERE id = ? AND revoked_at IS NULL
+	`, time.Now().UTC(), nullString(revokedBy), id)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		link, err := s.GetShareLink(ctx, id)
+		if err != nil {
+			return err
+		}
+		if link == nil {
+			return fmt.Errorf("share link not found")
+		}
+	}
+	return nil
+}
+
+// RecordShareLinkAccess counts a successful view of a share link.
+func (s *BaseStore) RecordShareLinkAccess(ctx context.Context, id int64, at time.Time) error {
+	_, err := s.execContext(ctx, `
+		UPDATE share_links SET access_count = access_count + 1, last_accessed_at = ?
+		WHERE id = ?
+	`, at.UTC(), id)
+	return err
+}
+
+func scanShareLink(row interface{ Scan(...interface{}) error }) (*ShareLink, error) {
+	var link ShareLink
+	var name, filter, passwordHash, revokedBy, createdBy sql.NullString
+	var reportRunID sql.NullInt64
+	var revokedAt, lastAccessed sql.NullTime
+	if err := row.Scan(
+		&link.ID, &link.TenantID, &link.Kind, &name, &filter, &reportRunID,
+		&link.TokenHash, &passwordHash, &link.ExpiresAt, &revokedAt, &revokedBy,
+		&link.AccessCount, &lastAccessed, &createdBy, &link.CreatedAt,
+	); err != nil {
+		return nil, err
+	}
+	link.Name = name.String
+	if filter.Valid && filter.String != "" {
+		if err := json.Unmarshal([]byte(filter.String), &link.Filter); err != nil {
+			return nil, fmt.Errorf("decode share link filter: %w", err)
+		}
+	}
+	if reportRunID.Valid {
+		id := reportRunID.Int64
+		link.ReportRunID = &id
+	}
+	link.PasswordHash = passwordHash.String
+	link.HasPassword = passwordHash.String != ""
+	if revokedAt.Valid {
+		t := revokedAt.Time
+		link.RevokedAt = &t
+	}
+	link.RevokedBy = revokedBy.String
+	if lastAccessed.Valid {
+		t := lastAccessed.Time
+		link.LastAccessedAt = &t
+	}
+	link.CreatedBy = createdBy.String
+	return &link, nil
+}
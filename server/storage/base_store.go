@@ -13,6 +13,7 @@ import (
 	"time"
 
 	pmsettings "printmaster/common/settings"
+	"printmaster/server/devicestatus"
 )
 
 // BaseStore provides shared database operations that work across SQLite and PostgreSQL.
@@ -142,9 +143,10 @@ func (s *BaseStore) RegisterAgent(ctx context.Context, agent *Agent) error {
 			agent_id, name, hostname, ip, platform, version, protocol_version, token, tenant_id,
 			registered_at, last_seen, status,
 			os_version, go_version, architecture, num_cpu, total_memory_mb,
-			build_type, git_commit, last_heartbeat
+			build_type, git_commit, last_heartbeat, token_issued_at, token_expires_at,
+			token_rotation_requested
 		)
-		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
 		ON CONFLICT(agent_id) DO UPDATE SET
 			name = CASE WHEN COALESCE(agents.name, '') = '' THEN excluded.name ELSE agents.name END,
 			hostname = excluded.hostname,
@@ -163,16 +165,27 @@ func (s *BaseStore) RegisterAgent(ctx context.Context, agent *Agent) error {
 			total_memory_mb = excluded.total_memory_mb,
 			build_type = excluded.build_type,
 			git_commit = excluded.git_commit,
-			last_heartbeat = excluded.last_heartbeat
+			last_heartbeat = excluded.last_heartbeat,
+			token_issued_at = excluded.token_issued_at,
+			token_expires_at = excluded.token_expires_at,
+			previous_token = NULL,
+			previous_token_expires_at = NULL,
+			token_resend_nonce = NULL,
+			token_rotation_requested = excluded.token_rotation_requested
 	`
 
+	if agent.TokenIssuedAt.IsZero() {
+		agent.TokenIssuedAt = time.Now().UTC()
+	}
+
 	// Use upsertReturningID which handles both Postgres RETURNING and SQLite LastInsertId
 	id, err := s.upsertReturningID(ctx, query,
 		agent.AgentID, agent.Name, agent.Hostname, agent.IP, agent.Platform,
 		agent.Version, agent.ProtocolVersion, agent.Token, agent.TenantID, agent.RegisteredAt,
 		agent.LastSeen, agent.Status,
 		agent.OSVersion, agent.GoVersion, agent.Architecture, agent.NumCPU,
-		agent.TotalMemoryMB, agent.BuildType, agent.GitCommit, agent.LastHeartbeat)
+		agent.TotalMemoryMB, agent.BuildType, agent.GitCommit, agent.LastHeartbeat,
+		agent.TokenIssuedAt, nullTime(agent.TokenExpiresAt), boolToInt(false))
 	if err != nil {
 		return err
 	}
@@ -192,17 +205,21 @@ func (s *BaseStore) GetAgent(ctx context.Context, agentID string) (*Agent, error
 		       token, tenant_id, registered_at, last_seen, status,
 		       os_version, go_version, architecture, num_cpu, total_memory_mb,
 		       build_type, git_commit, last_heartbeat, device_count,
-		       last_device_sync, last_metrics_sync
+		       last_device_sync, last_metrics_sync,
+		       token_issued_at, token_expires_at, token_rotation_requested,
+		       runtime_environment
 		FROM agents
 		WHERE agent_id = ?
 	`
 
 	var agent Agent
-	var name, osVersion, goVersion, architecture, buildType, gitCommit sql.NullString
+	var name, osVersion, goVersion, architecture, buildType, gitCommit, runtimeEnv sql.NullString
 	var numCPU, deviceCount sql.NullInt64
 	var totalMemoryMB sql.NullInt64
 	var lastHeartbeat, lastDeviceSync, lastMetricsSync sql.NullTime
 	var tenantID sql.NullString
+	var tokenIssuedAt, tokenExpiresAt sql.NullTime
+	var rotationRequested interface{}
 
 	err := s.queryRowContext(ctx, query, agentID).Scan(
 		&agent.ID, &agent.AgentID, &name, &agent.Hostname, &agent.IP,
@@ -211,6 +228,7 @@ func (s *BaseStore) GetAgent(ctx context.Context, agentID string) (*Agent, error
 		&osVersion, &goVersion, &architecture, &numCPU, &totalMemoryMB,
 		&buildType, &gitCommit, &lastHeartbeat, &deviceCount,
 		&lastDeviceSync, &lastMetricsSync,
+		&tokenIssuedAt, &tokenExpiresAt, &rotationRequested, &runtimeEnv,
 	)
 
 	if err == sql.ErrNoRows {
@@ -227,6 +245,7 @@ func (s *BaseStore) GetAgent(ctx context.Context, agentID string) (*Agent, error
 	agent.Architecture = architecture.String
 	agent.BuildType = buildType.String
 	agent.GitCommit = gitCommit.String
+	agent.RuntimeEnvironment = runtimeEnv.String
 	agent.TenantID = tenantID.String
 	if numCPU.Valid {
 		agent.NumCPU = int(numCPU.Int64)
@@ -246,36 +265,51 @@ func (s *BaseStore) GetAgent(ctx context.Context, agentID string) (*Agent, error
 	if lastMetricsSync.Valid {
 		agent.LastMetricsSync = lastMetricsSync.Time
 	}
+	if tokenIssuedAt.Valid {
+		agent.TokenIssuedAt = tokenIssuedAt.Time
+	}
+	if tokenExpiresAt.Valid {
+		agent.TokenExpiresAt = tokenExpiresAt.Time
+	}
+	agent.TokenRotationRequested = intToBool(rotationRequested)
 
 	return &agent, nil
 }
 
-// GetAgentByToken retrieves an agent by its authentication token
+// GetAgentByToken retrieves an agent by its authentication token. The token
+// replaced by the last rotation is also accepted until its grace period ends;
+// callers can tell by comparing the result's Token with the one presented.
 func (s *BaseStore) GetAgentByToken(ctx context.Context, token string) (*Agent, error) {
 	query := `
 		SELECT id, agent_id, name, hostname, ip, platform, version, protocol_version,
 		       token, tenant_id, registered_at, last_seen, status,
 		       os_version, go_version, architecture, num_cpu, total_memory_mb,
 		       build_type, git_commit, last_heartbeat, device_count,
-		       last_device_sync, last_metrics_sync
+		       last_device_sync, last_metrics_sync,
+		       token_issued_at, token_expires_at, token_rotation_requested,
+		       runtime_environment
 		FROM agents
-		WHERE token = ?
+		WHERE token = ? OR (previous_token = ? AND previous_token_expires_at > ?)
 	`
 
 	var agent Agent
-	var name, osVersion, goVersion, architecture, buildType, gitCommit sql.NullString
+	var name, osVersion, goVersion, architecture, buildType, gitCommit, runtimeEnv sql.NullString
 	var numCPU, deviceCount sql.NullInt64
 	var totalMemoryMB sql.NullInt64
 	var lastHeartbeat, lastDeviceSync, lastMetricsSync sql.NullTime
 	var tenantID sql.NullString
+	var tokenIssuedAt, tokenExpiresAt sql.NullTime
+	var rotationRequested interface{}
 
-	err := s.queryRowContext(ctx, query, token).Scan(
+	now := time.Now().UTC()
+	err := s.queryRowContext(ctx, query, token, token, now).Scan(
 		&agent.ID, &agent.AgentID, &name, &agent.Hostname, &agent.IP,
 		&agent.Platform, &agent.Version, &agent.ProtocolVersion,
 		&agent.Token, &tenantID, &agent.RegisteredAt, &agent.LastSeen, &agent.Status,
 		&osVersion, &goVersion, &architecture, &numCPU, &totalMemoryMB,
 		&buildType, &gitCommit, &lastHeartbeat, &deviceCount,
 		&lastDeviceSync, &lastMetricsSync,
+		&tokenIssuedAt, &tokenExpiresAt, &rotationRequested, &runtimeEnv,
 	)
 
 	if err == sql.ErrNoRows {
@@ -291,6 +325,7 @@ func (s *BaseStore) GetAgentByToken(ctx context.Context, token string) (*Agent,
 	agent.Architecture = architecture.String
 	agent.BuildType = buildType.String
 	agent.GitCommit = gitCommit.String
+	agent.RuntimeEnvironment = runtimeEnv.String
 	agent.TenantID = tenantID.String
 	if numCPU.Valid {
 		agent.NumCPU = int(numCPU.Int64)
@@ -310,6 +345,17 @@ func (s *BaseStore) GetAgentByToken(ctx context.Context, token string) (*Agent,
 	if lastMetricsSync.Valid {
 		agent.LastMetricsSync = lastMetricsSync.Time
 	}
+	if tokenIssuedAt.Valid {
+		agent.TokenIssuedAt = tokenIssuedAt.Time
+	}
+	if tokenExpiresAt.Valid {
+		agent.TokenExpiresAt = tokenExpiresAt.Time
+	}
+	agent.TokenRotationRequested = intToBool(rotationRequested)
+
+	if agent.Token == token && !agent.TokenExpiresAt.IsZero() && !now.Before(agent.TokenExpiresAt) {
+		return nil, ErrAgentTokenExpired
+	}
 
 	return &agent, nil
 }
@@ -321,7 +367,9 @@ func (s *BaseStore) ListAgents(ctx context.Context) ([]*Agent, error) {
 		       token, tenant_id, registered_at, last_seen, status,
 		       os_version, go_version, architecture, num_cpu, total_memory_mb,
 		       build_type, git_commit, last_heartbeat, device_count,
-		       last_device_sync, last_metrics_sync
+		       last_device_sync, last_metrics_sync,
+		       token_issued_at, token_expires_at, token_rotation_requested,
+		       runtime_environment
 		FROM agents
 		ORDER BY last_seen DESC
 	`
@@ -373,7 +421,9 @@ func (s *BaseStore) ListAgentsPaginated(ctx context.Context, limit, offset int,
 			       token, tenant_id, registered_at, last_seen, status,
 			       os_version, go_version, architecture, num_cpu, total_memory_mb,
 			       build_type, git_commit, last_heartbeat, device_count,
-			       last_device_sync, last_metrics_sync
+			       last_device_sync, last_metrics_sync,
+			       token_issued_at, token_expires_at, token_rotation_requested,
+			       runtime_environment
 			FROM agents
 			ORDER BY last_seen DESC
 			LIMIT ? OFFSET ?
@@ -390,7 +440,9 @@ func (s *BaseStore) ListAgentsPaginated(ctx context.Context, limit, offset int,
 			       token, tenant_id, registered_at, last_seen, status,
 			       os_version, go_version, architecture, num_cpu, total_memory_mb,
 			       build_type, git_commit, last_heartbeat, device_count,
-			       last_device_sync, last_metrics_sync
+			       last_device_sync, last_metrics_sync,
+			       token_issued_at, token_expires_at, token_rotation_requested,
+			       runtime_environment
 			FROM agents
 			WHERE tenant_id IN (%s)
 			ORDER BY last_seen DESC
@@ -420,11 +472,13 @@ func (s *BaseStore) ListAgentsPaginated(ctx context.Context, limit, offset int,
 // scanAgent scans an agent from a row scanner (works with both *sql.Row and *sql.Rows)
 func (s *BaseStore) scanAgent(rows *sql.Rows) (*Agent, error) {
 	var agent Agent
-	var name, osVersion, goVersion, architecture, buildType, gitCommit sql.NullString
+	var name, osVersion, goVersion, architecture, buildType, gitCommit, runtimeEnv sql.NullString
 	var numCPU, deviceCount sql.NullInt64
 	var totalMemoryMB sql.NullInt64
 	var lastHeartbeat, lastDeviceSync, lastMetricsSync sql.NullTime
 	var tenantID sql.NullString
+	var tokenIssuedAt, tokenExpiresAt sql.NullTime
+	var rotationRequested interface{}
 
 	err := rows.Scan(
 		&agent.ID, &agent.AgentID, &name, &agent.Hostname, &agent.IP,
@@ -433,6 +487,7 @@ func (s *BaseStore) scanAgent(rows *sql.Rows) (*Agent, error) {
 		&osVersion, &goVersion, &architecture, &numCPU, &totalMemoryMB,
 		&buildType, &gitCommit, &lastHeartbeat, &deviceCount,
 		&lastDeviceSync, &lastMetricsSync,
+		&tokenIssuedAt, &tokenExpiresAt, &rotationRequested, &runtimeEnv,
 	)
 	if err != nil {
 		return nil, err
@@ -444,6 +499,7 @@ func (s *BaseStore) scanAgent(rows *sql.Rows) (*Agent, error) {
 	agent.Architecture = architecture.String
 	agent.BuildType = buildType.String
 	agent.GitCommit = gitCommit.String
+	agent.RuntimeEnvironment = runtimeEnv.String
 	agent.TenantID = tenantID.String
 	if numCPU.Valid {
 		agent.NumCPU = int(numCPU.Int64)
@@ -463,6 +519,13 @@ func (s *BaseStore) scanAgent(rows *sql.Rows) (*Agent, error) {
 	if lastMetricsSync.Valid {
 		agent.LastMetricsSync = lastMetricsSync.Time
 	}
+	if tokenIssuedAt.Valid {
+		agent.TokenIssuedAt = tokenIssuedAt.Time
+	}
+	if tokenExpiresAt.Valid {
+		agent.TokenExpiresAt = tokenExpiresAt.Time
+	}
+	agent.TokenRotationRequested = intToBool(rotationRequested)
 
 	return &agent, nil
 }
@@ -479,6 +542,7 @@ func (s *BaseStore) UpdateAgentHeartbeat(ctx context.Context, agentID string, st
 // UpdateAgentInfo updates agent metadata (version, platform, etc.) typically on heartbeat.
 // Note: This does NOT update the 'name' field - that's managed separately via UpdateAgentName
 // to preserve user-set display names from being overwritten by agent heartbeats.
+// An empty RuntimeEnvironment keeps the stored value, since not every heartbeat carries it.
 func (s *BaseStore) UpdateAgentInfo(ctx context.Context, agent *Agent) error {
 	query := `
 		UPDATE agents SET
@@ -497,7 +561,8 @@ func (s *BaseStore) UpdateAgentInfo(ctx context.Context, agent *Agent) error {
 			device_count = ?,
 			last_seen = ?,
 			last_heartbeat = ?,
-			status = ?
+			status = ?,
+			runtime_environment = COALESCE(NULLIF(?, ''), runtime_environment)
 		WHERE agent_id = ?
 	`
 
@@ -507,7 +572,7 @@ func (s *BaseStore) UpdateAgentInfo(ctx context.Context, agent *Agent) error {
 		agent.OSVersion, agent.GoVersion, agent.Architecture,
 		agent.NumCPU, agent.TotalMemoryMB, agent.BuildType, agent.GitCommit,
 		agent.DeviceCount, agent.LastSeen, agent.LastHeartbeat, agent.Status,
-		agent.AgentID)
+		agent.RuntimeEnvironment, agent.AgentID)
 
 	return err
 }
@@ -592,8 +657,11 @@ func (s *BaseStore) UpsertDevice(ctx context.Context, device *Device) error {
 		device.DeviceType, device.SourceType, device.IsUSB, device.PortName,
 		device.DriverName, device.IsDefault, device.IsShared, device.SpoolerStatus,
 		device.UsbWebUIAvailable)
+	if err != nil {
+		return err
+	}
 
-	return err
+	return s.recordDeviceChange(ctx, device.Serial, device.AgentID, DeviceChangeKindDevice, DeviceChangeOpUpsert, deviceChangeContent(device))
 }
 
 // GetDevice retrieves a device by serial number
@@ -871,6 +939,10 @@ func (s *BaseStore) DeleteDevice(ctx context.Context, serial string, deleteMetri
 		return fmt.Errorf("failed to delete credentials: %w", err)
 	}
 
+	// Remember the owning agent for the change feed tombstone
+	var agentID sql.NullString
+	_ = s.queryRowContext(ctx, `SELECT agent_id FROM devices WHERE serial = ?`, serial).Scan(&agentID)
+
 	// Delete the device itself
 	result, err := s.execContext(ctx, `DELETE FROM devices WHERE serial = ?`, serial)
 	if err != nil {
@@ -885,7 +957,7 @@ func (s *BaseStore) DeleteDevice(ctx context.Context, serial string, deleteMetri
 		return fmt.Errorf("device not found: %s", serial)
 	}
 
-	return nil
+	return s.recordDeviceChange(ctx, serial, agentID.String, DeviceChangeKindDevice, DeviceChangeOpDelete, nil)
 }
 
 // ============================================================================
@@ -897,23 +969,28 @@ func (s *BaseStore) SaveMetrics(ctx context.Context, metrics *MetricsSnapshot) e
 	tonerJSON, _ := json.Marshal(metrics.TonerLevels)
 
 	query := `
-		INSERT INTO metrics_history (serial, agent_id, timestamp, page_count, color_pages, mono_pages, scan_count, toner_levels)
-		VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+		INSERT INTO metrics_history (serial, agent_id, timestamp, page_count, color_pages, mono_pages, scan_count,
+			duplex_sheets, simplex_sheets, nup_sheets, toner_levels)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
 	`
 
 	_, err := s.execContext(ctx, query,
 		metrics.Serial, metrics.AgentID, metrics.Timestamp,
-		metrics.PageCount, metrics.ColorPages, metrics.MonoPages,
-		metrics.ScanCount, string(tonerJSON))
+		metrics.PageCount, metrics.ColorPages, metrics.MonoPages, metrics.ScanCount,
+		metrics.DuplexSheets, metrics.SimplexSheets, metrics.NUpSheets, string(tonerJSON))
+	if err != nil {
+		return err
+	}
 
-	return err
+	return s.recordDeviceChange(ctx, metrics.Serial, metrics.AgentID, DeviceChangeKindMetrics, DeviceChangeOpUpsert, metricsChangeContent(metrics))
 }
 
 // GetLatestMetrics retrieves the most recent metrics for a device
 func (s *BaseStore) GetLatestMetrics(ctx context.Context, serial string) (*MetricsSnapshot, error) {
 	// Note: id column is not included - it may not exist after TimescaleDB hypertable conversion
 	query := `
-		SELECT serial, agent_id, timestamp, page_count, color_pages, mono_pages, scan_count, toner_levels
+		SELECT serial, agent_id, timestamp, page_count, color_pages, mono_pages, scan_count, COALESCE(duplex_sheets, 0),
+			COALESCE(simplex_sheets, 0), COALESCE(nup_sheets, 0), toner_levels
 		FROM metrics_history
 		WHERE serial = ?
 		ORDER BY timestamp DESC
@@ -925,7 +1002,7 @@ func (s *BaseStore) GetLatestMetrics(ctx context.Context, serial string) (*Metri
 
 	err := s.queryRowContext(ctx, query, serial).Scan(
 		&m.Serial, &m.AgentID, &m.Timestamp,
-		&m.PageCount, &m.ColorPages, &m.MonoPages, &m.ScanCount, &tonerJSON)
+		&m.PageCount, &m.ColorPages, &m.MonoPages, &m.ScanCount, &m.DuplexSheets, &m.SimplexSheets, &m.NUpSheets, &tonerJSON)
 
 	if err == sql.ErrNoRows {
 		return nil, nil
@@ -997,7 +1074,8 @@ func (s *BaseStore) GetLatestMetricsBatch(ctx context.Context, serials []string)
 func (s *BaseStore) GetMetricsHistory(ctx context.Context, serial string, since time.Time) ([]*MetricsSnapshot, error) {
 	// Note: id column is not included - it may not exist after TimescaleDB hypertable conversion
 	query := `
-		SELECT serial, agent_id, timestamp, page_count, color_pages, mono_pages, scan_count, toner_levels
+		SELECT serial, agent_id, timestamp, page_count, color_pages, mono_pages, scan_count, COALESCE(duplex_sheets, 0),
+			COALESCE(simplex_sheets, 0), COALESCE(nup_sheets, 0), toner_levels
 		FROM metrics_history
 		WHERE serial = ? AND timestamp >= ?
 		ORDER BY timestamp ASC
@@ -1015,7 +1093,7 @@ func (s *BaseStore) GetMetricsHistory(ctx context.Context, serial string, since
 		var tonerJSON sql.NullString
 
 		err := rows.Scan(&m.Serial, &m.AgentID, &m.Timestamp,
-			&m.PageCount, &m.ColorPages, &m.MonoPages, &m.ScanCount, &tonerJSON)
+			&m.PageCount, &m.ColorPages, &m.MonoPages, &m.ScanCount, &m.DuplexSheets, &m.SimplexSheets, &m.NUpSheets, &tonerJSON)
 		if err != nil {
 			return nil, err
 		}
@@ -1058,7 +1136,8 @@ func (s *BaseStore) GetMetricsBounds(ctx context.Context, serial string) (minTS,
 func (s *BaseStore) GetMetricsAtOrBefore(ctx context.Context, serial string, at time.Time) (*MetricsSnapshot, error) {
 	// Note: id column is not included - it may not exist after TimescaleDB hypertable conversion
 	query := `
-		SELECT serial, agent_id, timestamp, page_count, color_pages, mono_pages, scan_count, toner_levels
+		SELECT serial, agent_id, timestamp, page_count, color_pages, mono_pages, scan_count, COALESCE(duplex_sheets, 0),
+			COALESCE(simplex_sheets, 0), COALESCE(nup_sheets, 0), toner_levels
 		FROM metrics_history
 		WHERE serial = ? AND timestamp <= ?
 		ORDER BY timestamp DESC
@@ -1070,7 +1149,7 @@ func (s *BaseStore) GetMetricsAtOrBefore(ctx context.Context, serial string, at
 
 	err := s.queryRowContext(ctx, query, serial, at).Scan(
 		&m.Serial, &m.AgentID, &m.Timestamp,
-		&m.PageCount, &m.ColorPages, &m.MonoPages, &m.ScanCount, &tonerJSON)
+		&m.PageCount, &m.ColorPages, &m.MonoPages, &m.ScanCount, &m.DuplexSheets, &m.SimplexSheets, &m.NUpSheets, &tonerJSON)
 
 	if err == sql.ErrNoRows {
 		return nil, nil
@@ -1111,14 +1190,14 @@ func (s *BaseStore) CreateTenant(ctx context.Context, tenant *Tenant) error {
 	query := `
 		INSERT INTO tenants (
 			id, name, description, contact_name, contact_email, contact_phone,
-			business_unit, billing_code, address, login_domain, created_at
-		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+			business_unit, billing_code, address, login_domain, kind, created_at
+		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
 	`
 
 	_, err := s.execContext(ctx, query,
 		tenant.ID, tenant.Name, tenant.Description, tenant.ContactName, tenant.ContactEmail,
 		tenant.ContactPhone, tenant.BusinessUnit, tenant.BillingCode, tenant.Address,
-		nullString(tenant.LoginDomain), tenant.CreatedAt)
+		nullString(tenant.LoginDomain), nullString(tenant.Kind), tenant.CreatedAt)
 	return err
 }
 
@@ -1143,13 +1222,15 @@ func (s *BaseStore) UpdateTenant(ctx context.Context, tenant *Tenant) error {
 			business_unit = ?,
 			billing_code = ?,
 			address = ?,
-			login_domain = ?
+			login_domain = ?,
+			kind = ?
 		WHERE id = ?
 	`
 
 	res, err := s.execContext(ctx, query,
 		tenant.Name, tenant.Description, tenant.ContactName, tenant.ContactEmail, tenant.ContactPhone,
-		tenant.BusinessUnit, tenant.BillingCode, tenant.Address, nullString(tenant.LoginDomain), tenant.ID)
+		tenant.BusinessUnit, tenant.BillingCode, tenant.Address, nullString(tenant.LoginDomain),
+		nullString(tenant.Kind), tenant.ID)
 	if err != nil {
 		return err
 	}
@@ -1163,7 +1244,7 @@ func (s *BaseStore) UpdateTenant(ctx context.Context, tenant *Tenant) error {
 func (s *BaseStore) GetTenant(ctx context.Context, id string) (*Tenant, error) {
 	query := `
 		SELECT id, name, description, contact_name, contact_email, contact_phone,
-		       business_unit, billing_code, address, login_domain, created_at
+		       business_unit, billing_code, address, login_domain, COALESCE(kind, ''), created_at
 		FROM tenants WHERE id = ?
 	`
 
@@ -1172,7 +1253,7 @@ func (s *BaseStore) GetTenant(ctx context.Context, id string) (*Tenant, error) {
 	err := s.queryRowContext(ctx, query, id).Scan(
 		&t.ID, &t.Name, &t.Description, &t.ContactName, &t.ContactEmail,
 		&t.ContactPhone, &t.BusinessUnit, &t.BillingCode, &t.Address,
-		&loginDomain, &t.CreatedAt)
+		&loginDomain, &t.Kind, &t.CreatedAt)
 	if err != nil {
 		return nil, err
 	}
@@ -1184,7 +1265,7 @@ func (s *BaseStore) GetTenant(ctx context.Context, id string) (*Tenant, error) {
 func (s *BaseStore) ListTenants(ctx context.Context) ([]*Tenant, error) {
 	query := `
 		SELECT id, name, description, contact_name, contact_email, contact_phone,
-		       business_unit, billing_code, address, login_domain, created_at
+		       business_unit, billing_code, address, login_domain, COALESCE(kind, ''), created_at
 		FROM tenants ORDER BY created_at DESC
 	`
 
@@ -1200,7 +1281,7 @@ func (s *BaseStore) ListTenants(ctx context.Context) ([]*Tenant, error) {
 		var loginDomain sql.NullString
 		if err := rows.Scan(&t.ID, &t.Name, &t.Description, &t.ContactName, &t.ContactEmail,
 			&t.ContactPhone, &t.BusinessUnit, &t.BillingCode, &t.Address,
-			&loginDomain, &t.CreatedAt); err != nil {
+			&loginDomain, &t.Kind, &t.CreatedAt); err != nil {
 			return nil, err
 		}
 		t.LoginDomain = loginDomain.String
@@ -1218,7 +1299,7 @@ func (s *BaseStore) FindTenantByDomain(ctx context.Context, domain string) (*Ten
 
 	query := `
 		SELECT id, name, description, contact_name, contact_email, contact_phone,
-		       business_unit, billing_code, address, login_domain, created_at
+		       business_unit, billing_code, address, login_domain, COALESCE(kind, ''), created_at
 		FROM tenants WHERE login_domain = ?
 	`
 
@@ -1227,7 +1308,7 @@ func (s *BaseStore) FindTenantByDomain(ctx context.Context, domain string) (*Ten
 	err := s.queryRowContext(ctx, query, norm).Scan(
 		&t.ID, &t.Name, &t.Description, &t.ContactName, &t.ContactEmail,
 		&t.ContactPhone, &t.BusinessUnit, &t.BillingCode, &t.Address,
-		&loginDomain, &t.CreatedAt)
+		&loginDomain, &t.Kind, &t.CreatedAt)
 	if err == sql.ErrNoRows {
 		return nil, nil
 	}
@@ -1264,6 +1345,13 @@ func (s *BaseStore) DeleteTenant(ctx context.Context, id string) error {
 	// Explicitly delete fleet update policies
 	_, _ = s.execContext(ctx, `DELETE FROM fleet_update_policies WHERE tenant_id = ?`, id)
 
+	// Explicitly delete feature flag overrides
+	_, _ = s.execContext(ctx, `DELETE FROM feature_flags WHERE tenant_id = ?`, id)
+
+	// Explicitly delete onboarding progress and the dashboard layout
+	_, _ = s.execContext(ctx, `DELETE FROM tenant_onboarding WHERE tenant_id = ?`, id)
+	_, _ = s.execContext(ctx, `DELETE FROM tenant_dashboards WHERE tenant_id = ?`, id)
+
 	// Delete the tenant itself
 	query := `DELETE FROM tenants WHERE id = ?`
 	res, err := s.execContext(ctx, query, id)
@@ -1636,6 +1724,9 @@ func (s *BaseStore) UpdateUser(ctx context.Context, user *User) error {
 
 // DeleteUser removes a user
 func (s *BaseStore) DeleteUser(ctx context.Context, id int64) error {
+	if _, err := s.execContext(ctx, `DELETE FROM user_notification_prefs WHERE user_id = ?`, id); err != nil {
+		return err
+	}
 	_, err := s.execContext(ctx, `DELETE FROM users WHERE id = ?`, id)
 	return err
 }
@@ -1696,6 +1787,11 @@ func (s *BaseStore) loadUserTenantIDs(ctx context.Context, userID int64) ([]stri
 
 // CreateSession creates a new session token
 func (s *BaseStore) CreateSession(ctx context.Context, userID int64, ttlMinutes int) (*Session, error) {
+	return s.CreateSessionForClient(ctx, userID, ttlMinutes, "", "")
+}
+
+// CreateSessionForClient creates a new session token recording the client it was issued to
+func (s *BaseStore) CreateSessionForClient(ctx context.Context, userID int64, ttlMinutes int, ipAddress, userAgent string) (*Session, error) {
 	rawToken, err := generateSecureToken(32)
 	if err != nil {
 		return nil, fmt.Errorf("failed to generate session token: %w", err)
@@ -1704,41 +1800,83 @@ func (s *BaseStore) CreateSession(ctx context.Context, userID int64, ttlMinutes
 
 	expiresAt := time.Now().UTC().Add(time.Duration(ttlMinutes) * time.Minute)
 	createdAt := time.Now().UTC()
+	if len(userAgent) > 512 {
+		userAgent = userAgent[:512]
+	}
 
-	query := `INSERT INTO sessions (token, user_id, expires_at, created_at) VALUES (?, ?, ?, ?)`
-	_, err = s.execContext(ctx, query, tokenHash, userID, expiresAt, createdAt)
+	query := `INSERT INTO sessions (token, user_id, expires_at, created_at, last_seen_at, ip_address, user_agent) VALUES (?, ?, ?, ?, ?, ?, ?)`
+	_, err = s.execContext(ctx, query, tokenHash, userID, expiresAt, createdAt, createdAt, nullString(ipAddress), nullString(userAgent))
 	if err != nil {
 		return nil, err
 	}
 
 	return &Session{
-		Token:     rawToken,
-		UserID:    userID,
-		ExpiresAt: expiresAt,
-		CreatedAt: createdAt,
+		Token:      rawToken,
+		UserID:     userID,
+		ExpiresAt:  expiresAt,
+		CreatedAt:  createdAt,
+		LastSeenAt: createdAt,
+		IPAddress:  ipAddress,
+		UserAgent:  userAgent,
+		Kind:       SessionKindWeb,
 	}, nil
 }
 
+// sessionColumns selects a session row joined with users as u
+const sessionColumns = `s.token, s.user_id, s.expires_at, s.created_at, s.last_seen_at, s.ip_address, s.user_agent, u.username, s.kind, s.name, s.expiry_warned_at`
+
+func scanSession(row interface{ Scan(...interface{}) error }) (*Session, error) {
+	var sess Session
+	var lastSeen, warned sql.NullTime
+	var ip, ua, username, kind, name sql.NullString
+	if err := row.Scan(&sess.Token, &sess.UserID, &sess.ExpiresAt, &sess.CreatedAt, &lastSeen, &ip, &ua, &username, &kind, &name, &warned); err != nil {
+		return nil, err
+	}
+	sess.Kind = SessionKindWeb
+	if kind.Valid && kind.String != "" {
+		sess.Kind = kind.String
+	}
+	sess.Name = name.String
+	if warned.Valid {
+		t := warned.Time
+		sess.ExpiryWarnedAt = &t
+	}
+	sess.LastSeenAt = sess.CreatedAt
+	if lastSeen.Valid {
+		sess.LastSeenAt = lastSeen.Time
+	}
+	sess.IPAddress = ip.String
+	sess.UserAgent = ua.String
+	sess.Username = username.String
+	return &sess, nil
+}
+
 // GetSessionByToken retrieves a session by raw token
 func (s *BaseStore) GetSessionByToken(ctx context.Context, token string) (*Session, error) {
 	tokenHash := hashSHA256(token)
 
 	query := `
-		SELECT s.token, s.user_id, s.expires_at, s.created_at, u.username
+		SELECT ` + sessionColumns + `
 		FROM sessions s
 		JOIN users u ON s.user_id = u.id
 		WHERE s.token = ? AND s.expires_at > ?
 	`
+	return scanSession(s.queryRowContext(ctx, query, tokenHash, time.Now().UTC()))
+}
 
-	var sess Session
-	var username sql.NullString
-	err := s.queryRowContext(ctx, query, tokenHash, time.Now().UTC()).Scan(
-		&sess.Token, &sess.UserID, &sess.ExpiresAt, &sess.CreatedAt, &username)
+// TouchSession records activity on a session by its stored token hash
+func (s *BaseStore) TouchSession(ctx context.Context, tokenHash string, seenAt time.Time) error {
+	_, err := s.execContext(ctx, `UPDATE sessions SET last_seen_at = ? WHERE token = ?`, seenAt.UTC(), tokenHash)
+	return err
+}
+
+// DeleteUserSessions removes every session of a user except exceptHash and returns the number deleted
+func (s *BaseStore) DeleteUserSessions(ctx context.Context, userID int64, exceptHash string) (int64, error) {
+	result, err := s.execContext(ctx, `DELETE FROM sessions WHERE user_id = ? AND token != ?`, userID, exceptHash)
 	if err != nil {
-		return nil, err
+		return 0, err
 	}
-	sess.Username = username.String
-	return &sess, nil
+	return result.RowsAffected()
 }
 
 // DeleteSession removes a session by raw token
@@ -1775,7 +1913,7 @@ func (s *BaseStore) DeleteExpiredSessions(ctx context.Context) (int64, error) {
 // ListSessions returns all sessions
 func (s *BaseStore) ListSessions(ctx context.Context) ([]*Session, error) {
 	query := `
-		SELECT s.token, s.user_id, s.expires_at, s.created_at, u.username
+		SELECT ` + sessionColumns + `
 		FROM sessions s
 		LEFT JOIN users u ON s.user_id = u.id
 		ORDER BY s.created_at DESC
@@ -1789,13 +1927,11 @@ func (s *BaseStore) ListSessions(ctx context.Context) ([]*Session, error) {
 
 	var sessions []*Session
 	for rows.Next() {
-		var sess Session
-		var username sql.NullString
-		if err := rows.Scan(&sess.Token, &sess.UserID, &sess.ExpiresAt, &sess.CreatedAt, &username); err != nil {
+		sess, err := scanSession(rows)
+		if err != nil {
 			return nil, err
 		}
-		sess.Username = username.String
-		sessions = append(sessions, &sess)
+		sessions = append(sessions, sess)
 	}
 	return sessions, rows.Err()
 }
@@ -4500,7 +4636,7 @@ func (s *BaseStore) GetAggregatedMetrics(ctx context.Context, since time.Time, t
 		serialMap[d.Serial] = struct{}{}
 		deviceBySerial[d.Serial] = d
 
-		hasError, hasWarning, hasJam := classifyStatusMessages(d.StatusMessages)
+		hasError, hasWarning, hasJam := classifyStatusMessages(d.Manufacturer, d.StatusMessages)
 		if hasJam {
 			agg.Fleet.Statuses.Jam++
 			agg.Fleet.Statuses.Error++
@@ -4757,21 +4893,8 @@ func (s *BaseStore) GetDatabaseStats(ctx context.Context) (*DatabaseStats, error
 
 // Helper functions for aggregated metrics
 
-func classifyStatusMessages(messages []string) (bool, bool, bool) {
-	var hasError, hasWarning, hasJam bool
-	for _, msg := range messages {
-		lower := strings.ToLower(msg)
-		switch {
-		case strings.Contains(lower, "jam"):
-			hasJam = true
-			hasError = true
-		case strings.Contains(lower, "error") || strings.Contains(lower, "fail") || strings.Contains(lower, "offline"):
-			hasError = true
-		case strings.Contains(lower, "warn") || strings.Contains(lower, "low"):
-			hasWarning = true
-		}
-	}
-	return hasError, hasWarning, hasJam
+func classifyStatusMessages(vendor string, messages []string) (bool, bool, bool) {
+	return devicestatus.Active().Flags(vendor, messages)
 }
 
 func classifyConsumableBand(snapshot *MetricsSnapshot, device *Device) consumableBand {
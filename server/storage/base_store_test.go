@@ -85,6 +85,7 @@ func TestAgentLifecycle(t *testing.T) {
 	agent.Architecture = "amd64"
 	agent.NumCPU = 4
 	agent.TotalMemoryMB = 8192
+	agent.RuntimeEnvironment = "systemd"
 	err = s.UpdateAgentInfo(ctx, agent)
 	if err != nil {
 		t.Fatalf("UpdateAgentInfo: %v", err)
@@ -97,6 +98,19 @@ func TestAgentLifecycle(t *testing.T) {
 	if got.OSVersion != "Ubuntu 22.04" {
 		t.Errorf("os_version not updated: got=%q", got.OSVersion)
 	}
+	if got.RuntimeEnvironment != "systemd" {
+		t.Errorf("runtime_environment not updated: got=%q", got.RuntimeEnvironment)
+	}
+
+	// Heartbeats without a runtime environment keep the stored one
+	agent.RuntimeEnvironment = ""
+	if err := s.UpdateAgentInfo(ctx, agent); err != nil {
+		t.Fatalf("UpdateAgentInfo: %v", err)
+	}
+	got, _ = s.GetAgent(ctx, "agent-uuid-123")
+	if got.RuntimeEnvironment != "systemd" {
+		t.Errorf("runtime_environment cleared: got=%q", got.RuntimeEnvironment)
+	}
 
 	// Update agent name
 	err = s.UpdateAgentName(ctx, "agent-uuid-123", "Renamed Agent")
@@ -355,13 +369,16 @@ func TestMetricsLifecycle(t *testing.T) {
 
 	// Save metrics
 	metrics := &MetricsSnapshot{
-		Serial:     "SN-METRICS",
-		AgentID:    "agent-1",
-		Timestamp:  time.Now(),
-		PageCount:  1000,
-		ColorPages: 300,
-		MonoPages:  700,
-		ScanCount:  50,
+		Serial:        "SN-METRICS",
+		AgentID:       "agent-1",
+		Timestamp:     time.Now(),
+		PageCount:     1000,
+		ColorPages:    300,
+		MonoPages:     700,
+		ScanCount:     50,
+		DuplexSheets:  120,
+		SimplexSheets: 760,
+		NUpSheets:     15,
 		TonerLevels: map[string]interface{}{
 			"black": 80,
 			"cyan":  60,
@@ -387,6 +404,9 @@ func TestMetricsLifecycle(t *testing.T) {
 	if latest.ColorPages != 300 {
 		t.Errorf("color_pages mismatch: got=%d", latest.ColorPages)
 	}
+	if latest.DuplexSheets != 120 || latest.SimplexSheets != 760 || latest.NUpSheets != 15 {
+		t.Errorf("sheet counters mismatch: duplex=%d simplex=%d nup=%d", latest.DuplexSheets, latest.SimplexSheets, latest.NUpSheets)
+	}
 
 	// Save another snapshot
 	time.Sleep(10 * time.Millisecond) // ensure different timestamp
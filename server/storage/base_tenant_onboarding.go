@@ -0,0 +1,116 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// ============================================================
+// Tenant Onboarding Storage Methods (BaseStore)
+// ============================================================
+
+// ListTenantOnboardingSteps returns the checklist steps a tenant's operators
+// marked done or skipped.
+func (s *BaseStore) ListTenantOnboardingSteps(ctx context.Context, tenantID string) ([]*TenantOnboardingStep, error) {
+	rows, err := s.queryContext(ctx, `
+		SELECT tenant_id, step, status, updated_at, COALESCE(updated_by, '')
+		FROM tenant_onboarding WHERE tenant_id = ? ORDER BY step
+	`, tenantID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var steps []*TenantOnboardingStep
+	for rows.Next() {
+		var step TenantOnboardingStep
+		if err := rows.Scan(&step.TenantID, &step.Step, &step.Status, &step.UpdatedAt, &step.UpdatedBy); err != nil {
+			return nil, err
+		}
+		steps = append(steps, &step)
+	}
+	return steps, rows.Err()
+}
+
+// SetTenantOnboardingStep marks a checklist step done or skipped.
+func (s *BaseStore) SetTenantOnboardingStep(ctx context.Context, step *TenantOnboardingStep) error {
+	if step == nil || step.TenantID == "" || step.Step == "" {
+		return fmt.Errorf("tenant_id and step are required")
+	}
+	if step.Status != OnboardingStepDone && step.Status != OnboardingStepSkipped {
+		return fmt.Errorf("invalid onboarding status %q", step.Status)
+	}
+	step.UpdatedAt = time.Now().UTC()
+
+	_, err := s.execContext(ctx, `
+		INSERT INTO tenant_onboarding (tenant_id, step, status, updated_at, updated_by)
+		VALUES (?, ?, ?, ?, ?)
+		ON CONFLICT(tenant_id, step) DO UPDATE SET
+			status = excluded.status,
+			updated_at = excluded.updated_at,
+			updated_by = excluded.updated_by
+	`, step.TenantID, step.Step, step.Status, step.UpdatedAt, step.UpdatedBy)
+	return err
+}
+
+// ClearTenantOnboardingStep removes a manual status so the step is judged
+// from the tenant's data again.
+func (s *BaseStore) ClearTenantOnboardingStep(ctx context.Context, tenantID, step string) error {
+	_, err := s.execContext(ctx, `DELETE FROM tenant_onboarding WHERE tenant_id = ? AND step = ?`, tenantID, step)
+	return err
+}
+
+// GetTenantDashboard returns a tenant's customized dashboard layout or nil
+// when the tenant uses the default for its kind.
+func (s *BaseStore) GetTenantDashboard(ctx context.Context, tenantID string) (*TenantDashboard, error) {
+	var d TenantDashboard
+	var layout string
+	err := s.queryRowContext(ctx, `
+		SELECT tenant_id, layout, updated_at, COALESCE(updated_by, '')
+		FROM tenant_dashboards WHERE tenant_id = ?
+	`, tenantID).Scan(&d.TenantID, &layout, &d.UpdatedAt, &d.UpdatedBy)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal([]byte(layout), &d.Layout); err != nil {
+		return nil, fmt.Errorf("failed to decode dashboard layout: %w", err)
+	}
+	return &d, nil
+}
+
+// UpsertTenantDashboard stores a tenant's dashboard layout.
+func (s *BaseStore) UpsertTenantDashboard(ctx context.Context, d *TenantDashboard) error {
+	if d == nil || d.TenantID == "" {
+		return fmt.Errorf("tenant_id is required")
+	}
+	if d.Layout.Widgets == nil {
+		d.Layout.Widgets = []string{}
+	}
+	layout, err := json.Marshal(d.Layout)
+	if err != nil {
+		return fmt.Errorf("failed to encode dashboard layout: %w", err)
+	}
+	d.UpdatedAt = time.Now().UTC()
+
+	_, err = s.execContext(ctx, `
+		INSERT INTO tenant_dashboards (tenant_id, layout, updated_at, updated_by)
+		VALUES (?, ?, ?, ?)
+		ON CONFLICT(tenant_id) DO UPDATE SET
+			layout = excluded.layout,
+			updated_at = excluded.updated_at,
+			updated_by = excluded.updated_by
+	`, d.TenantID, string(layout), d.UpdatedAt, d.UpdatedBy)
+	return err
+}
+
+// DeleteTenantDashboard resets a tenant to the default layout for its kind.
+func (s *BaseStore) DeleteTenantDashboard(ctx context.Context, tenantID string) error {
+	_, err := s.execContext(ctx, `DELETE FROM tenant_dashboards WHERE tenant_id = ?`, tenantID)
+	return err
+}
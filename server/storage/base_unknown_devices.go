@@ -0,0 +1,132 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ============================================================
+// Unknown Device Telemetry Storage Methods (BaseStore)
+// ============================================================
+
+// SaveUnknownDeviceReport stores an agent's report for a sysObjectID/sysDescr
+// pair. Agents count sightings since they started, so the stored count only
+// grows: a smaller count after an agent restart doesn't lower it.
+func (s *BaseStore) SaveUnknownDeviceReport(ctx context.Context, r *UnknownDeviceReport) error {
+	if r == nil || r.AgentID == "" || (r.SysObjectID == "" && r.SysDescr == "") {
+		return fmt.Errorf("agent_id and sys_object_id or sys_descr are required")
+	}
+	now := time.Now().UTC()
+	if r.FirstSeen.IsZero() {
+		r.FirstSeen = now
+	}
+	if r.LastSeen.IsZero() {
+		r.LastSeen = now
+	}
+	_, err := s.execContext(ctx, `
+		INSERT INTO unknown_device_reports (
+			agent_id, tenant_id, sys_object_id, sys_descr, enterprise, model,
+			sightings, first_seen, last_seen, received_at
+		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT(agent_id, sys_object_id, sys_descr) DO UPDATE SET
+			tenant_id = excluded.tenant_id,
+			enterprise = excluded.enterprise,
+			model = CASE WHEN excluded.model <> '' THEN excluded.model ELSE unknown_device_reports.model END,
+			sightings = CASE WHEN excluded.sightings > unknown_device_reports.sightings
+				THEN excluded.sightings ELSE unknown_device_reports.sightings END,
+			last_seen = excluded.last_seen,
+			received_at = excluded.received_at
+	`, r.AgentID, nullString(r.TenantID), r.SysObjectID, r.SysDescr, r.Enterprise, r.Model,
+		r.Sightings, r.FirstSeen.UTC(), r.LastSeen.UTC(), now)
+	if err != nil {
+		return fmt.Errorf("save unknown device report: %w", err)
+	}
+	return nil
+}
+
+// ListUnknownDevices aggregates reports per sysObjectID/sysDescr pair, most
+// widespread first.
+func (s *BaseStore) ListUnknownDevices(ctx context.Context, filter UnknownDeviceFilter) ([]*UnknownDevice, error) {
+	var where []string
+	var args []interface{}
+	if len(filter.TenantIDs) > 0 {
+		where = append(where, "tenant_id IN ("+placeholderList(len(filter.TenantIDs))+")")
+		for _, id := range filter.TenantIDs {
+			args = append(args, id)
+		}
+	}
+	if filter.Enterprise != "" {
+		where = append(where, "enterprise = ?")
+		args = append(args, filter.Enterprise)
+	}
+	query := `SELECT agent_id, sys_object_id, sys_descr, enterprise, model, sightings, first_seen, last_seen
+		FROM unknown_device_reports`
+	if len(where) > 0 {
+		query += " WHERE " + strings.Join(where, " AND ")
+	}
+
+	rows, err := s.queryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	byKey := make(map[string]*UnknownDevice)
+	agents := make(map[string]map[string]bool)
+	for rows.Next() {
+		var agentID, sysObjectID, sysDescr string
+		var enterprise, model sql.NullString
+		var sightings int
+		var firstSeen, lastSeen time.Time
+		if err := rows.Scan(&agentID, &sysObjectID, &sysDescr, &enterprise, &model, &sightings, &firstSeen, &lastSeen); err != nil {
+			return nil, err
+		}
+		key := sysObjectID + "|" + sysDescr
+		d, ok := byKey[key]
+		if !ok {
+			d = &UnknownDevice{SysObjectID: sysObjectID, SysDescr: sysDescr, FirstSeen: firstSeen, LastSeen: lastSeen}
+			byKey[key] = d
+			agents[key] = map[string]bool{}
+		}
+		if d.Enterprise == "" {
+			d.Enterprise = enterprise.String
+		}
+		if d.Model == "" {
+			d.Model = model.String
+		}
+		d.Sightings += sightings
+		if firstSeen.Before(d.FirstSeen) {
+			d.FirstSeen = firstSeen
+		}
+		if lastSeen.After(d.LastSeen) {
+			d.LastSeen = lastSeen
+		}
+		agents[key][agentID] = true
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	out := make([]*UnknownDevice, 0, len(byKey))
+	for key, d := range byKey {
+		d.Agents = len(agents[key])
+		out = append(out, d)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Agents != out[j].Agents {
+			return out[i].Agents > out[j].Agents
+		}
+		if out[i].Sightings != out[j].Sightings {
+			return out[i].Sightings > out[j].Sightings
+		}
+		return out[i].SysObjectID < out[j].SysObjectID
+	})
+	if filter.Limit > 0 && len(out) > filter.Limit {
+		out = out[:filter.Limit]
+	}
+	return out, nil
+}
@@ -0,0 +1,271 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+	"time"
+)
+
+// ============================================================
+// Vendor Cloud Webhook Storage Methods (BaseStore)
+// ============================================================
+
+const vendorDeliveryColumns = `id, tenant_id, source, delivery_id, status, COALESCE(error, ''), events, matched, unmatched,
+	COALESCE(source_ip, ''), received_at`
+
+const vendorReadingColumns = `id, delivery_id, source, serial, COALESCE(agent_id, ''), COALESCE(tenant_id, ''),
+	kind, metric, value, COALESCE(severity, ''), COALESCE(state, ''), COALESCE(message, ''), observed_at,
+	received_at, COALESCE(reconcile, ''), agent_value, agent_observed_at, COALESCE(alert_id, 0)`
+
+// SetVendorWebhookSecret creates or replaces the secret for a tenant and source.
+func (s *BaseStore) SetVendorWebhookSecret(ctx context.Context, secret *VendorWebhookSecret) error {
+	if secret.CreatedAt.IsZero() {
+		secret.CreatedAt = time.Now().UTC()
+	}
+	_, err := s.execContext(ctx, `
+		INSERT INTO vendor_webhook_secrets (tenant_id, source, secret, created_by, created_at)
+		VALUES (?, ?, ?, ?, ?)
+		ON CONFLICT (tenant_id, source) DO UPDATE SET secret = excluded.secret,
+			created_by = excluded.created_by, created_at = excluded.created_at
+	`, secret.TenantID, secret.Source, secret.Secret, nullString(secret.CreatedBy), secret.CreatedAt.UTC())
+	return err
+}
+
+// GetVendorWebhookSecret returns the secret for a tenant and source, or nil
+// if none is set.
+func (s *BaseStore) GetVendorWebhookSecret(ctx context.Context, tenantID, source string) (*VendorWebhookSecret, error) {
+	var v VendorWebhookSecret
+	err := s.queryRowContext(ctx, `SELECT tenant_id, source, secret, COALESCE(created_by, ''), created_at
+		FROM vendor_webhook_secrets WHERE tenant_id = ? AND source = ?`, tenantID, source).
+		Scan(&v.TenantID, &v.Source, &v.Secret, &v.CreatedBy, &v.CreatedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &v, nil
+}
+
+// ListVendorWebhookSecrets returns every configured tenant and source, without
+// the secrets themselves.
+func (s *BaseStore) ListVendorWebhookSecrets(ctx context.Context) ([]*VendorWebhookSecret, error) {
+	rows, err := s.queryContext(ctx, `SELECT tenant_id, source, COALESCE(created_by, ''), created_at
+		FROM vendor_webhook_secrets ORDER BY tenant_id, source`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var secrets []*VendorWebhookSecret
+	for rows.Next() {
+		var v VendorWebhookSecret
+		if err := rows.Scan(&v.TenantID, &v.Source, &v.CreatedBy, &v.CreatedAt); err != nil {
+			return nil, err
+		}
+		secrets = append(secrets, &v)
+	}
+	return secrets, rows.Err()
+}
+
+// DeleteVendorWebhookSecret removes the secret for a tenant and source.
+// Returns ErrVendorWebhookSecretNotFound when none was set.
+func (s *BaseStore) DeleteVendorWebhookSecret(ctx context.Context, tenantID, source string) error {
+	res, err := s.execContext(ctx, `DELETE FROM vendor_webhook_secrets WHERE tenant_id = ? AND source = ?`, tenantID, source)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrVendorWebhookSecretNotFound
+	}
+	return nil
+}
+
+// SaveVendorWebhookDelivery stores a received webhook and the readings it
+// carried, setting their IDs.
+func (s *BaseStore) SaveVendorWebhookDelivery(ctx context.Context, d *VendorWebhookDelivery, readings []*VendorReading) error {
+	if d.ReceivedAt.IsZero() {
+		d.ReceivedAt = time.Now().UTC()
+	}
+	id, err := s.insertReturningID(ctx, `
+		INSERT INTO vendor_webhook_deliveries (tenant_id, source, delivery_id, status, error, events, matched, unmatched, source_ip, received_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`, d.TenantID, d.Source, d.DeliveryID, d.Status, nullString(d.Error), d.Events, d.Matched, d.Unmatched,
+		nullString(d.SourceIP), d.ReceivedAt.UTC())
+	if err != nil {
+		return err
+	}
+	d.ID = id
+
+	for _, r := range readings {
+		r.DeliveryID = d.ID
+		if r.ReceivedAt.IsZero() {
+			r.ReceivedAt = d.ReceivedAt
+		}
+		var agentValue sql.NullInt64
+		if r.AgentValue != nil {
+			agentValue = sql.NullInt64{Int64: int64(*r.AgentValue), Valid: true}
+		}
+		var agentObserved sql.NullTime
+		if r.AgentObservedAt != nil {
+			agentObserved = sql.NullTime{Time: r.AgentObservedAt.UTC(), Valid: true}
+		}
+		var alertID sql.NullInt64
+		if r.AlertID > 0 {
+			alertID = sql.NullInt64{Int64: r.AlertID, Valid: true}
+		}
+		rid, err := s.insertReturningID(ctx, `
+			INSERT INTO vendor_readings (delivery_id, source, serial, agent_id, tenant_id, kind, metric, value,
+				severity, state, message, observed_at, received_at, reconcile, agent_value, agent_observed_at, alert_id)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		`, r.DeliveryID, r.Source, r.Serial, nullString(r.AgentID), nullString(r.TenantID), r.Kind, r.Metric, r.Value,
+			nullString(r.Severity), nullString(r.State), nullString(r.Message), r.ObservedAt.UTC(), r.ReceivedAt.UTC(),
+			nullString(r.Reconcile), agentValue, agentObserved, alertID)
+		if err != nil {
+			return err
+		}
+		r.ID = rid
+	}
+	return nil
+}
+
+// GetVendorWebhookDelivery returns the delivery a source sent a tenant with
+// deliveryID, or nil if none was received.
+func (s *BaseStore) GetVendorWebhookDelivery(ctx context.Context, tenantID, source, deliveryID string) (*VendorWebhookDelivery, error) {
+	row := s.queryRowContext(ctx, `SELECT `+vendorDeliveryColumns+` FROM vendor_webhook_deliveries
+		WHERE tenant_id = ? AND source = ? AND delivery_id = ?`, tenantID, source, deliveryID)
+	d, err := scanVendorWebhookDelivery(row)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return d, err
+}
+
+// ListVendorWebhookDeliveries returns received webhooks, newest first.
+func (s *BaseStore) ListVendorWebhookDeliveries(ctx context.Context, filter VendorWebhookDeliveryFilter) ([]*VendorWebhookDelivery, error) {
+	var where []string
+	var args []interface{}
+	if filter.TenantID != "" {
+		where = append(where, "tenant_id = ?")
+		args = append(args, filter.TenantID)
+	}
+	if filter.Source != "" {
+		where = append(where, "source = ?")
+		args = append(args, filter.Source)
+	}
+	query := `SELECT ` + vendorDeliveryColumns + ` FROM vendor_webhook_deliveries`
+	if len(where) > 0 {
+		query += " WHERE " + strings.Join(where, " AND ")
+	}
+	query += " ORDER BY received_at DESC, id DESC"
+	if filter.Limit > 0 {
+		query += " LIMIT ?"
+		args = append(args, filter.Limit)
+	}
+
+	rows, err := s.queryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var deliveries []*VendorWebhookDelivery
+	for rows.Next() {
+		d, err := scanVendorWebhookDelivery(rows)
+		if err != nil {
+			return nil, err
+		}
+		deliveries = append(deliveries, d)
+	}
+	return deliveries, rows.Err()
+}
+
+// ListVendorReadings returns vendor readings, newest first.
+func (s *BaseStore) ListVendorReadings(ctx context.Context, filter VendorReadingFilter) ([]*VendorReading, error) {
+	var where []string
+	var args []interface{}
+	for _, f := range []struct {
+		column, value string
+	}{
+		{"serial", filter.Serial},
+		{"source", filter.Source},
+		{"kind", filter.Kind},
+		{"reconcile", filter.Reconcile},
+	} {
+		if f.value != "" {
+			where = append(where, f.column+" = ?")
+			args = append(args, f.value)
+		}
+	}
+	if filter.DeliveryID > 0 {
+		where = append(where, "delivery_id = ?")
+		args = append(args, filter.DeliveryID)
+	}
+	if len(filter.TenantIDs) > 0 {
+		where = append(where, "tenant_id IN ("+placeholderList(len(filter.TenantIDs))+")")
+		for _, id := range filter.TenantIDs {
+			args = append(args, id)
+		}
+	}
+
+	query := `SELECT ` + vendorReadingColumns + ` FROM vendor_readings`
+	if len(where) > 0 {
+		query += " WHERE " + strings.Join(where, " AND ")
+	}
+	query += " ORDER BY observed_at DESC, id DESC"
+	if filter.Limit > 0 {
+		query += " LIMIT ?"
+		args = append(args, filter.Limit)
+	}
+
+	rows, err := s.queryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var readings []*VendorReading
+	for rows.Next() {
+		var r VendorReading
+		var agentValue sql.NullInt64
+		var agentObserved sql.NullTime
+		if err := rows.Scan(&r.ID, &r.DeliveryID, &r.Source, &r.Serial, &r.AgentID, &r.TenantID,
+			&r.Kind, &r.Metric, &r.Value, &r.Severity, &r.State, &r.Message, &r.ObservedAt,
+			&r.ReceivedAt, &r.Reconcile, &agentValue, &agentObserved, &r.AlertID); err != nil {
+			return nil, err
+		}
+		if agentValue.Valid {
+			v := int(agentValue.Int64)
+			r.AgentValue = &v
+		}
+		if agentObserved.Valid {
+			t := agentObserved.Time
+			r.AgentObservedAt = &t
+		}
+		readings = append(readings, &r)
+	}
+	return readings, rows.Err()
+}
+
+// DeleteVendorWebhookDataBefore prunes deliveries received before cutoff
+// together with their readings. Returns the number of deliveries removed.
+func (s *BaseStore) DeleteVendorWebhookDataBefore(ctx context.Context, cutoff time.Time) (int64, error) {
+	if _, err := s.execContext(ctx, `DELETE FROM vendor_readings WHERE received_at < ?`, cutoff.UTC()); err != nil {
+		return 0, err
+	}
+	res, err := s.execContext(ctx, `DELETE FROM vendor_webhook_deliveries WHERE received_at < ?`, cutoff.UTC())
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+func scanVendorWebhookDelivery(row interface{ Scan(...interface{}) error }) (*VendorWebhookDelivery, error) {
+	var d VendorWebhookDelivery
+	if err := row.Scan(&d.ID, &d.TenantID, &d.Source, &d.DeliveryID, &d.Status, &d.Error, &d.Events, &d.Matched,
+		&d.Unmatched, &d.SourceIP, &d.ReceivedAt); err != nil {
+		return nil, err
+	}
+	return &d, nil
+}
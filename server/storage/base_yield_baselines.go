@@ -0,0 +1,65 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+// ============================================================
+// Cartridge Yield Baseline Storage Methods (BaseStore)
+// ============================================================
+
+// ListYieldBaselines returns the cartridge baseline library in stored order.
+func (s *BaseStore) ListYieldBaselines(ctx context.Context) ([]YieldBaseline, error) {
+	rows, err := s.queryContext(ctx, `
+		SELECT id, printer_model, supply, cartridge_model, supplier, rated_yield, cartridge_cost, notes
+		FROM yield_baselines ORDER BY position, id
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var baselines []YieldBaseline
+	for rows.Next() {
+		var b YieldBaseline
+		var cartridge, supplier, notes sql.NullString
+		var cost sql.NullFloat64
+		if err := rows.Scan(&b.ID, &b.PrinterModel, &b.Supply, &cartridge, &supplier, &b.RatedYield, &cost, &notes); err != nil {
+			return nil, err
+		}
+		b.CartridgeCost = cost.Float64
+		b.CartridgeModel = cartridge.String
+		b.Supplier = supplier.String
+		b.Notes = notes.String
+		baselines = append(baselines, b)
+	}
+	return baselines, rows.Err()
+}
+
+// ReplaceYieldBaselines atomically replaces the cartridge baseline library.
+// Entries are stored in the order given.
+func (s *BaseStore) ReplaceYieldBaselines(ctx context.Context, baselines []YieldBaseline) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, s.query(`DELETE FROM yield_baselines`)); err != nil {
+		return err
+	}
+	now := time.Now().UTC()
+	insert := s.query(`
+		INSERT INTO yield_baselines (position, printer_model, supply, cartridge_model, supplier, rated_yield, cartridge_cost, notes, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`)
+	for i, b := range baselines {
+		cost := sql.NullFloat64{Float64: b.CartridgeCost, Valid: b.CartridgeCost > 0}
+		if _, err := tx.ExecContext(ctx, insert, i, b.PrinterModel, b.Supply, b.CartridgeModel, b.Supplier, b.RatedYield, cost, b.Notes, now); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
@@ -0,0 +1,62 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestBillingPeriodFinalizeIsImmutable(t *testing.T) {
+	t.Parallel()
+	s, err := NewSQLiteStore(":memory:")
+	if err != nil {
+		t.Fatalf("NewSQLiteStore: %v", err)
+	}
+	defer s.Close()
+	ctx := context.Background()
+
+	start := time.Date(2026, 9, 1, 0, 0, 0, 0, time.UTC)
+	bp := &BillingPeriod{
+		TenantID:       "t1",
+		Period:         "2026-09",
+		PeriodStart:    start,
+		PeriodEnd:      start.AddDate(0, 1, 0),
+		DevicesManaged: 4,
+		PagesMono:      1200,
+		PagesColor:     300,
+		PagesTotal:     1500,
+		AgentsOnline:   1,
+		AgentsTotal:    2,
+		ComputedAt:     start.AddDate(0, 1, 1),
+		FinalizedBy:    "billing",
+	}
+	if err := s.FinalizeBillingPeriod(ctx, bp); err != nil {
+		t.Fatalf("FinalizeBillingPeriod: %v", err)
+	}
+
+	again := *bp
+	again.PagesMono = 9999
+	if err := s.FinalizeBillingPeriod(ctx, &again); !errors.Is(err, ErrBillingPeriodFinalized) {
+		t.Fatalf("second finalize error = %v, want ErrBillingPeriodFinalized", err)
+	}
+
+	got, err := s.GetBillingPeriod(ctx, "t1", "2026-09")
+	if err != nil || got == nil {
+		t.Fatalf("GetBillingPeriod = %v, %v", got, err)
+	}
+	if got.PagesMono != 1200 || got.Status != BillingPeriodFinalized || got.FinalizedBy != "billing" {
+		t.Errorf("stored period changed: %+v", got)
+	}
+	if got.SchemaVersion != BillingSchemaVersion {
+		t.Errorf("SchemaVersion = %d, want %d", got.SchemaVersion, BillingSchemaVersion)
+	}
+
+	if missing, err := s.GetBillingPeriod(ctx, "t1", "2026-10"); err != nil || missing != nil {
+		t.Errorf("GetBillingPeriod(open period) = %v, %v; want nil", missing, err)
+	}
+	list, err := s.ListBillingPeriods(ctx, BillingPeriodFilter{Period: "2026-09"})
+	if err != nil || len(list) != 1 {
+		t.Fatalf("ListBillingPeriods = %d, %v", len(list), err)
+	}
+}
@@ -0,0 +1,48 @@
+package storage
+
+import (
+	"errors"
+	"time"
+)
+
+// Billing period status constants. Open periods are recomputed on every
+// export; finalized periods are stored and never change afterwards.
+const (
+	BillingPeriodOpen      = "open"
+	BillingPeriodFinalized = "finalized"
+)
+
+// BillingSchemaVersion is the version of the billing export schema. Bump it
+// only when fields are removed or change meaning; billing integrations key
+// off it.
+const BillingSchemaVersion = 1
+
+// ErrBillingPeriodFinalized is returned when finalizing a tenant period that
+// has already been locked.
+var ErrBillingPeriodFinalized = errors.New("billing period already finalized")
+
+// BillingPeriod holds the monthly billing counters for one tenant.
+type BillingPeriod struct {
+	TenantID       string    `json:"tenant_id"`
+	TenantName     string    `json:"tenant_name,omitempty"`
+	Period         string    `json:"period"` // YYYY-MM (UTC)
+	PeriodStart    time.Time `json:"period_start"`
+	PeriodEnd      time.Time `json:"period_end"` // Exclusive
+	DevicesManaged int       `json:"devices_managed"`
+	PagesMono      int64     `json:"pages_mono"`
+	PagesColor     int64     `json:"pages_color"`
+	PagesTotal     int64     `json:"pages_total"`
+	AgentsOnline   int       `json:"agents_online"` // Agents that checked in during the period
+	AgentsTotal    int       `json:"agents_total"`
+	Status         string    `json:"status"`
+	ComputedAt     time.Time `json:"computed_at"`
+	FinalizedAt    time.Time `json:"finalized_at,omitzero"`
+	FinalizedBy    string    `json:"finalized_by,omitempty"`
+	SchemaVersion  int       `json:"schema_version"`
+}
+
+// BillingPeriodFilter narrows ListBillingPeriods results.
+type BillingPeriodFilter struct {
+	Period    string   // Empty = all periods
+	TenantIDs []string // Empty = all tenants
+}
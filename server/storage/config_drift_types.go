@@ -0,0 +1,49 @@
+package storage
+
+import "time"
+
+// Config drift history events.
+const (
+	ConfigDriftEventDetected = "detected" // agent started deviating from policy (or deviates differently)
+	ConfigDriftEventResolved = "resolved" // agent is back in line with policy
+	ConfigDriftEventEnforced = "enforced" // an admin asked the agent to re-apply policy
+)
+
+// SettingsDriftField is one fleet-managed setting whose value on an agent
+// differs from the policy resolved for it. Values of secret fields (SNMP
+// communities and passphrases) are never stored; Redacted is set instead.
+type SettingsDriftField struct {
+	Path     string      `json:"path"`
+	Expected interface{} `json:"expected,omitempty"`
+	Reported interface{} `json:"reported,omitempty"`
+	Redacted bool        `json:"redacted,omitempty"`
+}
+
+// AgentConfigDrift is the latest comparison between the settings an agent
+// reported in a heartbeat and the settings policy assigns it.
+type AgentConfigDrift struct {
+	AgentID            string               `json:"agent_id"`
+	TenantID           string               `json:"tenant_id,omitempty"`
+	ExpectedVersion    string               `json:"expected_version,omitempty"`
+	ReportedVersion    string               `json:"reported_version,omitempty"`
+	Drifted            bool                 `json:"drifted"`
+	Fields             []SettingsDriftField `json:"fields,omitempty"`
+	CheckedAt          time.Time            `json:"checked_at"`
+	DriftSince         *time.Time           `json:"drift_since,omitempty"`
+	EnforceRequestedAt *time.Time           `json:"enforce_requested_at,omitempty"` // set until the agent picks up the re-sent policy
+	EnforceRequestedBy string               `json:"enforce_requested_by,omitempty"`
+	LastEnforcedAt     *time.Time           `json:"last_enforced_at,omitempty"`
+}
+
+// AgentConfigDriftEvent records a change in an agent's drift state.
+type AgentConfigDriftEvent struct {
+	ID              int64                `json:"id"`
+	AgentID         string               `json:"agent_id"`
+	TenantID        string               `json:"tenant_id,omitempty"`
+	Event           string               `json:"event"`
+	Fields          []SettingsDriftField `json:"fields,omitempty"`
+	ExpectedVersion string               `json:"expected_version,omitempty"`
+	ReportedVersion string               `json:"reported_version,omitempty"`
+	Actor           string               `json:"actor,omitempty"`
+	CreatedAt       time.Time            `json:"created_at"`
+}
@@ -0,0 +1,163 @@
+package storage
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Deep scan run and per-agent statuses.
+const (
+	DeepScanStatusPending   = "pending"
+	DeepScanStatusRunning   = "running"
+	DeepScanStatusCompleted = "completed"
+	DeepScanStatusPartial   = "partial" // some agents or devices failed
+	DeepScanStatusFailed    = "failed"
+	DeepScanStatusOffline   = "offline" // agent not connected when the run started
+)
+
+// DeepScanSchedule runs a server-initiated deep scan (full SNMP walk and
+// parse capture) across a set of agents at a fixed off-hours time. An empty
+// AgentIDs list targets every connected agent. Each agent scans its saved
+// devices when IncludeSaved is set, plus any Ranges (range text, one entry
+// per line).
+type DeepScanSchedule struct {
+	ID           int64      `json:"id"`
+	Name         string     `json:"name"`
+	Enabled      bool       `json:"enabled"`
+	AgentIDs     []string   `json:"agent_ids"`
+	Ranges       string     `json:"ranges,omitempty"`
+	IncludeSaved bool       `json:"include_saved"`
+	Frequency    string     `json:"frequency"` // daily or weekly
+	DayOfWeek    int        `json:"day_of_week,omitempty"`
+	TimeOfDay    string     `json:"time_of_day"`
+	Timezone     string     `json:"timezone"`
+	NextRunAt    time.Time  `json:"next_run_at"`
+	LastRunAt    *time.Time `json:"last_run_at,omitempty"`
+	LastRunID    *int64     `json:"last_run_id,omitempty"`
+	CreatedBy    string     `json:"created_by,omitempty"`
+	CreatedAt    time.Time  `json:"created_at"`
+	UpdatedAt    time.Time  `json:"updated_at"`
+}
+
+// Normalize trims fields and applies defaults.
+func (s *DeepScanSchedule) Normalize() {
+	s.Name = strings.TrimSpace(s.Name)
+	s.Ranges = strings.TrimSpace(s.Ranges)
+	s.Frequency = strings.ToLower(strings.TrimSpace(s.Frequency))
+	if s.Frequency == "" {
+		s.Frequency = ScheduleFrequencyDaily
+	}
+	s.TimeOfDay = strings.TrimSpace(s.TimeOfDay)
+	if s.TimeOfDay == "" {
+		s.TimeOfDay = "02:00"
+	}
+	s.Timezone = strings.TrimSpace(s.Timezone)
+	if s.Timezone == "" {
+		s.Timezone = "UTC"
+	}
+	ids := make([]string, 0, len(s.AgentIDs))
+	for _, id := range s.AgentIDs {
+		if id = strings.TrimSpace(id); id != "" {
+			ids = append(ids, id)
+		}
+	}
+	s.AgentIDs = ids
+}
+
+// Validate reports whether the schedule can be stored.
+func (s *DeepScanSchedule) Validate() error {
+	if s.Name == "" {
+		return fmt.Errorf("name is required")
+	}
+	if s.Frequency != ScheduleFrequencyDaily && s.Frequency != ScheduleFrequencyWeekly {
+		return fmt.Errorf("frequency must be daily or weekly")
+	}
+	if s.DayOfWeek < 0 || s.DayOfWeek > 6 {
+		return fmt.Errorf("day_of_week must be 0-6")
+	}
+	var hour, min int
+	if n, _ := fmt.Sscanf(s.TimeOfDay, "%d:%d", &hour, &min); n != 2 || hour < 0 || hour > 23 || min < 0 || min > 59 {
+		return fmt.Errorf("time_of_day must be HH:MM")
+	}
+	if _, err := time.LoadLocation(s.Timezone); err != nil {
+		return fmt.Errorf("unknown timezone %q", s.Timezone)
+	}
+	if !s.IncludeSaved && s.Ranges == "" {
+		return fmt.Errorf("include_saved or ranges is required")
+	}
+	return nil
+}
+
+// DeepScanRun is one execution of a deep scan across agents.
+type DeepScanRun struct {
+	ID              int64                  `json:"id"`
+	ScheduleID      *int64                 `json:"schedule_id,omitempty"`
+	Name            string                 `json:"name"`
+	Status          string                 `json:"status"`
+	TriggeredBy     string                 `json:"triggered_by"`
+	StartedAt       time.Time              `json:"started_at"`
+	CompletedAt     *time.Time             `json:"completed_at,omitempty"`
+	AgentsTotal     int                    `json:"agents_total"`
+	AgentsCompleted int                    `json:"agents_completed"`
+	AgentsFailed    int                    `json:"agents_failed"`
+	DevicesScanned  int                    `json:"devices_scanned"`
+	DevicesFailed   int                    `json:"devices_failed"`
+	DevicesChanged  int                    `json:"devices_changed"`
+	Agents          []*DeepScanAgentResult `json:"agents,omitempty"`
+}
+
+// DeepScanAgentResult is one agent's part of a deep scan run: its progress,
+// per-device captures and the differences against the agent's previous
+// completed scan.
+type DeepScanAgentResult struct {
+	ID          int64                  `json:"id"`
+	RunID       int64                  `json:"run_id"`
+	AgentID     string                 `json:"agent_id"`
+	Status      string                 `json:"status"`
+	JobID       string                 `json:"job_id,omitempty"`
+	Total       int                    `json:"total"`
+	Done        int                    `json:"done"`
+	Failed      int                    `json:"failed"`
+	Error       string                 `json:"error,omitempty"`
+	Results     []DeepScanDeviceResult `json:"results,omitempty"`
+	Diffs       []DeepScanDiff         `json:"diffs,omitempty"`
+	StartedAt   *time.Time             `json:"started_at,omitempty"`
+	CompletedAt *time.Time             `json:"completed_at,omitempty"`
+}
+
+// DeepScanDeviceResult is the parse capture for one device as reported by
+// the agent.
+type DeepScanDeviceResult struct {
+	IP           string                 `json:"ip"`
+	Serial       string                 `json:"serial,omitempty"`
+	Manufacturer string                 `json:"manufacturer,omitempty"`
+	Model        string                 `json:"model,omitempty"`
+	Fields       map[string]interface{} `json:"fields,omitempty"`
+	PDUCount     int                    `json:"pdu_count"`
+	DurationMS   int64                  `json:"duration_ms"`
+	Error        string                 `json:"error,omitempty"`
+}
+
+// Deep scan diff change kinds.
+const (
+	DeepScanChangeAdded   = "added"
+	DeepScanChangeRemoved = "removed"
+	DeepScanChangeChanged = "changed"
+)
+
+// DeepScanDiff describes how a device's capture differs from the previous
+// scan.
+type DeepScanDiff struct {
+	Serial string                `json:"serial,omitempty"`
+	IP     string                `json:"ip"`
+	Change string                `json:"change"`
+	Fields []DeepScanFieldChange `json:"fields,omitempty"`
+}
+
+// DeepScanFieldChange is one changed field of a device capture.
+type DeepScanFieldChange struct {
+	Field  string      `json:"field"`
+	Before interface{} `json:"before"`
+	After  interface{} `json:"after"`
+}
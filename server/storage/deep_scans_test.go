@@ -0,0 +1,105 @@
+package storage
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestDeepScanSchedulesAndRuns(t *testing.T) {
+	t.Parallel()
+	s, err := NewSQLiteStore(":memory:")
+	if err != nil {
+		t.Fatalf("NewSQLiteStore: %v", err)
+	}
+	defer s.Close()
+
+	ctx := context.Background()
+	now := time.Now().UTC().Truncate(time.Second)
+
+	due := &DeepScanSchedule{Name: "Nightly", Enabled: true, AgentIDs: []string{"a1", "a2"}, IncludeSaved: true,
+		Frequency: "daily", TimeOfDay: "02:00", Timezone: "UTC", NextRunAt: now.Add(-time.Minute), CreatedBy: "admin"}
+	later := &DeepScanSchedule{Name: "Weekly", Enabled: true, Ranges: "10.0.0.0/24",
+		Frequency: "weekly", TimeOfDay: "03:00", Timezone: "UTC", NextRunAt: now.Add(time.Hour)}
+	for _, sched := range []*DeepScanSchedule{due, later} {
+		if err := s.CreateDeepScanSchedule(ctx, sched); err != nil {
+			t.Fatalf("CreateDeepScanSchedule: %v", err)
+		}
+	}
+
+	dueList, err := s.GetDueDeepScanSchedules(ctx, now)
+	if err != nil {
+		t.Fatalf("GetDueDeepScanSchedules: %v", err)
+	}
+	if len(dueList) != 1 || dueList[0].ID != due.ID || len(dueList[0].AgentIDs) != 2 || !dueList[0].IncludeSaved {
+		t.Fatalf("unexpected due schedules: %+v", dueList)
+	}
+
+	scheduleID := due.ID
+	first := &DeepScanRun{ScheduleID: &scheduleID, Name: due.Name, Status: DeepScanStatusRunning, TriggeredBy: "scheduler", StartedAt: now, AgentsTotal: 1}
+	if err := s.CreateDeepScanRun(ctx, first); err != nil {
+		t.Fatalf("CreateDeepScanRun: %v", err)
+	}
+	if err := s.UpdateDeepScanScheduleAfterRun(ctx, due.ID, first.ID, now.Add(24*time.Hour)); err != nil {
+		t.Fatalf("UpdateDeepScanScheduleAfterRun: %v", err)
+	}
+	got, err := s.GetDeepScanSchedule(ctx, due.ID)
+	if err != nil || got == nil || got.LastRunID == nil || *got.LastRunID != first.ID {
+		t.Fatalf("expected last run recorded, got %+v, %v", got, err)
+	}
+
+	result := &DeepScanAgentResult{RunID: first.ID, AgentID: "a1", Status: DeepScanStatusPending}
+	if err := s.SaveDeepScanAgentResult(ctx, result); err != nil {
+		t.Fatalf("SaveDeepScanAgentResult (insert): %v", err)
+	}
+	result.Status = DeepScanStatusCompleted
+	result.Total, result.Done = 1, 1
+	result.Results = []DeepScanDeviceResult{{IP: "10.0.0.1", Serial: "S1", Fields: map[string]interface{}{"firmware": "1.0"}}}
+	result.Diffs = []DeepScanDiff{{Serial: "S1", IP: "10.0.0.1", Change: DeepScanChangeAdded}}
+	if err := s.SaveDeepScanAgentResult(ctx, result); err != nil {
+		t.Fatalf("SaveDeepScanAgentResult (update): %v", err)
+	}
+	first.Status = DeepScanStatusCompleted
+	first.AgentsCompleted, first.DevicesScanned, first.DevicesChanged = 1, 1, 1
+	completed := now.Add(time.Minute)
+	first.CompletedAt = &completed
+	if err := s.UpdateDeepScanRun(ctx, first); err != nil {
+		t.Fatalf("UpdateDeepScanRun: %v", err)
+	}
+
+	loaded, err := s.GetDeepScanRun(ctx, first.ID)
+	if err != nil || loaded == nil {
+		t.Fatalf("GetDeepScanRun: %+v, %v", loaded, err)
+	}
+	if loaded.Status != DeepScanStatusCompleted || loaded.CompletedAt == nil || len(loaded.Agents) != 1 {
+		t.Fatalf("unexpected run: %+v", loaded)
+	}
+	if a := loaded.Agents[0]; len(a.Results) != 1 || a.Results[0].Fields["firmware"] != "1.0" || len(a.Diffs) != 1 {
+		t.Fatalf("unexpected agent result: %+v", a)
+	}
+
+	second := &DeepScanRun{ScheduleID: &scheduleID, Name: due.Name, Status: DeepScanStatusRunning, StartedAt: now.Add(time.Hour)}
+	if err := s.CreateDeepScanRun(ctx, second); err != nil {
+		t.Fatalf("CreateDeepScanRun: %v", err)
+	}
+	prev, err := s.GetPreviousDeepScanAgentResult(ctx, "a1", second.ID)
+	if err != nil || prev == nil || prev.RunID != first.ID {
+		t.Fatalf("expected previous result from first run, got %+v, %v", prev, err)
+	}
+	if none, err := s.GetPreviousDeepScanAgentResult(ctx, "a2", second.ID); err != nil || none != nil {
+		t.Fatalf("expected no previous result for a2, got %+v, %v", none, err)
+	}
+
+	runs, err := s.ListDeepScanRuns(ctx, due.ID, 10)
+	if err != nil || len(runs) != 2 || runs[0].ID != second.ID {
+		t.Fatalf("expected newest run first, got %+v, %v", runs, err)
+	}
+
+	if err := s.DeleteDeepScanSchedule(ctx, later.ID); err != nil {
+		t.Fatalf("DeleteDeepScanSchedule: %v", err)
+	}
+	schedules, err := s.ListDeepScanSchedules(ctx)
+	if err != nil || len(schedules) != 1 {
+		t.Fatalf("expected one schedule after delete, got %+v, %v", schedules, err)
+	}
+}
@@ -0,0 +1,17 @@
+package storage
+
+import (
+	"time"
+
+	"printmaster/common/vendorrules"
+)
+
+// DetectionRule is a server-managed vendor detection rule. Rules are
+// fleet-wide: every agent receives the enabled rules with its managed
+// settings.
+type DetectionRule struct {
+	vendorrules.Rule
+	CreatedBy string    `json:"created_by,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
@@ -0,0 +1,81 @@
+package storage
+
+import (
+	"path"
+	"strings"
+	"time"
+)
+
+// Device approval status constants. Devices without an approval record are
+// treated as approved so fleets that never enable the workflow are unaffected.
+const (
+	DeviceApprovalPending  = "pending"
+	DeviceApprovalApproved = "approved"
+	DeviceApprovalRejected = "rejected"
+)
+
+// DeviceApproval tracks the review state of a device first uploaded while its
+// tenant had the approval workflow enabled.
+type DeviceApproval struct {
+	Serial       string    `json:"serial"`
+	TenantID     string    `json:"tenant_id,omitempty"`
+	AgentID      string    `json:"agent_id"`
+	Status       string    `json:"status"`
+	Manufacturer string    `json:"manufacturer,omitempty"`
+	Model        string    `json:"model,omitempty"`
+	IP           string    `json:"ip,omitempty"`
+	Reason       string    `json:"reason,omitempty"` // Why the current status was assigned (e.g. matched auto-approval rule)
+	CreatedAt    time.Time `json:"created_at"`
+	ReviewedAt   time.Time `json:"reviewed_at,omitempty"`
+	ReviewedBy   string    `json:"reviewed_by,omitempty"`
+	Notes        string    `json:"notes,omitempty"`
+}
+
+// DeviceApprovalFilter narrows ListDeviceApprovals results.
+type DeviceApprovalFilter struct {
+	Status    string   // Empty = all statuses
+	TenantIDs []string // Empty = all tenants
+	Limit     int      // 0 = no limit
+}
+
+// DeviceApprovalPolicy holds per-tenant approval workflow settings.
+type DeviceApprovalPolicy struct {
+	TenantID                 string    `json:"tenant_id"`
+	Enabled                  bool      `json:"enabled"`
+	AutoApproveManufacturers []string  `json:"auto_approve_manufacturers,omitempty"` // Case-insensitive glob patterns, e.g. "HP", "Kyocera*"
+	AutoApproveModels        []string  `json:"auto_approve_models,omitempty"`        // Case-insensitive glob patterns, e.g. "*LaserJet*"
+	UpdatedAt                time.Time `json:"updated_at"`
+	UpdatedBy                string    `json:"updated_by,omitempty"`
+}
+
+// AutoApproves reports whether a device matches one of the policy's
+// auto-approval rules. The returned string describes the matching rule.
+func (p *DeviceApprovalPolicy) AutoApproves(manufacturer, model string) (bool, string) {
+	if p == nil {
+		return false, ""
+	}
+	for _, pattern := range p.AutoApproveManufacturers {
+		if matchApprovalPattern(pattern, manufacturer) {
+			return true, "manufacturer matches " + strings.TrimSpace(pattern)
+		}
+	}
+	for _, pattern := range p.AutoApproveModels {
+		if matchApprovalPattern(pattern, model) {
+			return true, "model matches " + strings.TrimSpace(pattern)
+		}
+	}
+	return false, ""
+}
+
+func matchApprovalPattern(pattern, value string) bool {
+	pattern = strings.ToLower(strings.TrimSpace(pattern))
+	value = strings.ToLower(strings.TrimSpace(value))
+	if pattern == "" || value == "" {
+		return false
+	}
+	if !strings.ContainsAny(pattern, "*?[") {
+		return pattern == value
+	}
+	ok, err := path.Match(pattern, value)
+	return err == nil && ok
+}
@@ -0,0 +1,127 @@
+package storage
+
+import (
+	"context"
+	"testing"
+)
+
+func TestDeviceApprovalPolicyAutoApproves(t *testing.T) {
+	t.Parallel()
+
+	policy := &DeviceApprovalPolicy{
+		AutoApproveManufacturers: []string{"HP", "kyocera*"},
+		AutoApproveModels:        []string{"*LaserJet*"},
+	}
+	tests := []struct {
+		manufacturer, model string
+		want                bool
+	}{
+		{"hp", "OfficeJet", true},
+		{"Kyocera Mita", "TASKalfa", true},
+		{"Canon", "HP LaserJet clone", true},
+		{"Canon", "imageRUNNER", false},
+		{"", "", false},
+	}
+	for _, tt := range tests {
+		if got, _ := policy.AutoApproves(tt.manufacturer, tt.model); got != tt.want {
+			t.Errorf("AutoApproves(%q, %q) = %v, want %v", tt.manufacturer, tt.model, got, tt.want)
+		}
+	}
+	if got, _ := (*DeviceApprovalPolicy)(nil).AutoApproves("HP", ""); got {
+		t.Error("nil policy should not auto-approve")
+	}
+}
+
+func TestDeviceApprovalLifecycle(t *testing.T) {
+	t.Parallel()
+	s, err := NewSQLiteStore(":memory:")
+	if err != nil {
+		t.Fatalf("NewSQLiteStore: %v", err)
+	}
+	defer s.Close()
+
+	ctx := context.Background()
+
+	policy, err := s.GetDeviceApprovalPolicy(ctx, "tenant-a")
+	if err != nil {
+		t.Fatalf("GetDeviceApprovalPolicy (default): %v", err)
+	}
+	if policy.Enabled {
+		t.Fatal("default policy should be disabled")
+	}
+
+	policy.Enabled = true
+	policy.AutoApproveManufacturers = []string{"HP"}
+	policy.UpdatedBy = "admin"
+	if err := s.UpsertDeviceApprovalPolicy(ctx, policy); err != nil {
+		t.Fatalf("UpsertDeviceApprovalPolicy: %v", err)
+	}
+	policy, err = s.GetDeviceApprovalPolicy(ctx, "tenant-a")
+	if err != nil {
+		t.Fatalf("GetDeviceApprovalPolicy: %v", err)
+	}
+	if !policy.Enabled || len(policy.AutoApproveManufacturers) != 1 || policy.UpdatedBy != "admin" {
+		t.Fatalf("unexpected policy: %+v", policy)
+	}
+
+	for _, a := range []*DeviceApproval{
+		{Serial: "SN1", TenantID: "tenant-a", AgentID: "agent-1"},
+		{Serial: "SN2", TenantID: "tenant-a", AgentID: "agent-1"},
+		{Serial: "SN3", TenantID: "tenant-b", AgentID: "agent-2", Status: DeviceApprovalApproved},
+	} {
+		if err := s.CreateDeviceApproval(ctx, a); err != nil {
+			t.Fatalf("CreateDeviceApproval(%s): %v", a.Serial, err)
+		}
+	}
+	// Re-recording must not reset an existing decision.
+	if err := s.CreateDeviceApproval(ctx, &DeviceApproval{Serial: "SN3", AgentID: "agent-2"}); err != nil {
+		t.Fatalf("CreateDeviceApproval (duplicate): %v", err)
+	}
+	if got, _ := s.GetDeviceApproval(ctx, "SN3"); got == nil || got.Status != DeviceApprovalApproved {
+		t.Fatalf("SN3 should stay approved, got %+v", got)
+	}
+	if got, _ := s.GetDeviceApproval(ctx, "missing"); got != nil {
+		t.Fatalf("expected nil for unknown serial, got %+v", got)
+	}
+
+	pending, err := s.ListDeviceApprovals(ctx, DeviceApprovalFilter{Status: DeviceApprovalPending, TenantIDs: []string{"tenant-a"}})
+	if err != nil {
+		t.Fatalf("ListDeviceApprovals: %v", err)
+	}
+	if len(pending) != 2 {
+		t.Fatalf("expected 2 pending approvals, got %d", len(pending))
+	}
+
+	unapproved, err := s.ListUnapprovedDeviceSerials(ctx)
+	if err != nil {
+		t.Fatalf("ListUnapprovedDeviceSerials: %v", err)
+	}
+	if len(unapproved) != 2 {
+		t.Fatalf("expected 2 unapproved serials, got %v", unapproved)
+	}
+
+	n, err := s.SetDeviceApprovalStatus(ctx, []string{"SN1"}, DeviceApprovalApproved, "operator", "looks good")
+	if err != nil || n != 1 {
+		t.Fatalf("SetDeviceApprovalStatus approve: n=%d err=%v", n, err)
+	}
+	n, err = s.SetDeviceApprovalStatus(ctx, []string{"SN2"}, DeviceApprovalRejected, "operator", "")
+	if err != nil || n != 1 {
+		t.Fatalf("SetDeviceApprovalStatus reject: n=%d err=%v", n, err)
+	}
+	if _, err := s.SetDeviceApprovalStatus(ctx, []string{"SN1"}, "bogus", "operator", ""); err == nil {
+		t.Fatal("expected error for invalid status")
+	}
+
+	got, err := s.GetDeviceApproval(ctx, "SN1")
+	if err != nil || got == nil {
+		t.Fatalf("GetDeviceApproval: %v", err)
+	}
+	if got.Status != DeviceApprovalApproved || got.ReviewedBy != "operator" || got.ReviewedAt.IsZero() {
+		t.Fatalf("unexpected approval after review: %+v", got)
+	}
+
+	unapproved, _ = s.ListUnapprovedDeviceSerials(ctx)
+	if _, ok := unapproved["SN2"]; !ok || len(unapproved) != 1 {
+		t.Fatalf("expected only SN2 unapproved, got %v", unapproved)
+	}
+}
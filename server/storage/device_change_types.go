@@ -0,0 +1,33 @@
+package storage
+
+import "time"
+
+// Device change kinds and operations recorded in the change feed.
+const (
+	DeviceChangeKindDevice  = "device"
+	DeviceChangeKindMetrics = "metrics"
+
+	DeviceChangeOpUpsert = "upsert"
+	DeviceChangeOpDelete = "delete" // tombstone; the device is gone
+)
+
+// DeviceChange is one entry of the device change feed. ID is the cursor
+// clients resume from: it only ever increases. Writes that leave a device's
+// inventory fields or counters unchanged (e.g. a repeated upload that only
+// bumps last_seen) are not recorded.
+type DeviceChange struct {
+	ID        int64     `json:"id"`
+	Serial    string    `json:"serial"`
+	AgentID   string    `json:"agent_id,omitempty"`
+	Kind      string    `json:"kind"`
+	Op        string    `json:"op"`
+	ChangedAt time.Time `json:"changed_at"`
+}
+
+// DeviceChangeFilter narrows ListDeviceChanges. Zero values match everything.
+type DeviceChangeFilter struct {
+	After    int64    // Only changes with ID > After
+	AgentIDs []string // Empty = all agents
+	Kinds    []string // Empty = all kinds
+	Limit    int
+}
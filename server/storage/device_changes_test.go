@@ -0,0 +1,78 @@
+package storage
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestDeviceChangeFeed(t *testing.T) {
+	t.Parallel()
+	s, err := NewSQLiteStore(":memory:")
+	if err != nil {
+		t.Fatalf("NewSQLiteStore: %v", err)
+	}
+	defer s.Close()
+	ctx := context.Background()
+
+	dev := &Device{AgentID: "agent-1"}
+	dev.Serial, dev.IP, dev.Model = "SER1", "10.0.0.5", "M404"
+	if err := s.UpsertDevice(ctx, dev); err != nil {
+		t.Fatalf("UpsertDevice: %v", err)
+	}
+	// Re-uploading an unchanged device only bumps last_seen: no new change
+	dev.LastSeen = time.Now().Add(time.Minute)
+	if err := s.UpsertDevice(ctx, dev); err != nil {
+		t.Fatalf("UpsertDevice: %v", err)
+	}
+	dev.IP = "10.0.0.6"
+	if err := s.UpsertDevice(ctx, dev); err != nil {
+		t.Fatalf("UpsertDevice: %v", err)
+	}
+	for i, pages := range []int{100, 100, 150} {
+		m := &MetricsSnapshot{Serial: "SER1", AgentID: "agent-1", Timestamp: time.Now().Add(time.Duration(i) * time.Minute), PageCount: pages}
+		if err := s.SaveMetrics(ctx, m); err != nil {
+			t.Fatalf("SaveMetrics: %v", err)
+		}
+	}
+	if err := s.DeleteDevice(ctx, "SER1", true); err != nil {
+		t.Fatalf("DeleteDevice: %v", err)
+	}
+
+	changes, err := s.ListDeviceChanges(ctx, DeviceChangeFilter{})
+	if err != nil {
+		t.Fatalf("ListDeviceChanges: %v", err)
+	}
+	var got []string
+	for _, c := range changes {
+		got = append(got, c.Kind+":"+c.Op)
+		if c.AgentID != "agent-1" {
+			t.Errorf("change %d agent = %q", c.ID, c.AgentID)
+		}
+	}
+	want := []string{"device:upsert", "device:upsert", "metrics:upsert", "metrics:upsert", "device:delete"}
+	if len(got) != len(want) {
+		t.Fatalf("changes = %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("changes = %v, want %v", got, want)
+		}
+	}
+
+	after, _ := s.ListDeviceChanges(ctx, DeviceChangeFilter{After: changes[2].ID, Kinds: []string{DeviceChangeKindDevice}})
+	if len(after) != 1 || after[0].Op != DeviceChangeOpDelete {
+		t.Errorf("filtered changes = %+v", after)
+	}
+	if other, _ := s.ListDeviceChanges(ctx, DeviceChangeFilter{AgentIDs: []string{"agent-2"}}); len(other) != 0 {
+		t.Errorf("expected no changes for agent-2, got %d", len(other))
+	}
+
+	oldest, newest, err := s.GetDeviceChangeBounds(ctx)
+	if err != nil || oldest != changes[0].ID || newest != changes[4].ID {
+		t.Errorf("bounds = %d..%d (%v)", oldest, newest, err)
+	}
+	if n, err := s.DeleteDeviceChangesBefore(ctx, time.Now().Add(time.Hour)); err != nil || n != 5 {
+		t.Errorf("DeleteDeviceChangesBefore = %d, %v", n, err)
+	}
+}
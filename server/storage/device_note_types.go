@@ -0,0 +1,137 @@
+package storage
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Device note kinds. A note is free-form text; the other kinds are structured
+// maintenance log entries.
+const (
+	DeviceNoteKindNote           = "note"
+	DeviceNoteKindPartReplaced   = "part_replaced"
+	DeviceNoteKindServiceVisit   = "service_visit"
+	DeviceNoteKindFirmwareChange = "firmware_change"
+)
+
+// MaxDeviceNoteLength caps the text of a note or maintenance entry.
+const MaxDeviceNoteLength = 10000
+
+// DeviceNote is a user-authored note or maintenance log entry on a device.
+// OccurredAt is when the work happened, which may be earlier than when the
+// entry was written.
+type DeviceNote struct {
+	ID           int64     `json:"id"`
+	Serial       string    `json:"serial"`
+	TenantID     string    `json:"tenant_id,omitempty"`
+	Kind         string    `json:"kind"`
+	Body         string    `json:"body,omitempty"`
+	Part         string    `json:"part,omitempty"`          // part_replaced: part name or number
+	Vendor       string    `json:"vendor,omitempty"`        // service_visit: who did the work
+	FirmwareFrom string    `json:"firmware_from,omitempty"` // firmware_change
+	FirmwareTo   string    `json:"firmware_to,omitempty"`   // firmware_change
+	PageCount    *int64    `json:"page_count,omitempty"`    // Meter at the time of the work, if noted
+	Author       string    `json:"author"`
+	OccurredAt   time.Time `json:"occurred_at"`
+	CreatedAt    time.Time `json:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at"`
+}
+
+// DeviceNoteFilter narrows ListDeviceNotes. Zero values match everything.
+type DeviceNoteFilter struct {
+	Serial    string
+	TenantIDs []string // Empty = all tenants
+	Kinds     []string
+	Since     time.Time // OccurredAt >= Since
+	Until     time.Time // OccurredAt < Until
+	Limit     int
+}
+
+// IsDeviceNoteKind reports whether kind is a known device note kind.
+func IsDeviceNoteKind(kind string) bool {
+	switch kind {
+	case DeviceNoteKindNote, DeviceNoteKindPartReplaced, DeviceNoteKindServiceVisit, DeviceNoteKindFirmwareChange:
+		return true
+	}
+	return false
+}
+
+// Validate normalizes a note and checks the fields its kind requires.
+func (n *DeviceNote) Validate() error {
+	n.Serial = strings.TrimSpace(n.Serial)
+	n.Kind = strings.ToLower(strings.TrimSpace(n.Kind))
+	n.Body = strings.TrimSpace(n.Body)
+	n.Part = strings.TrimSpace(n.Part)
+	n.Vendor = strings.TrimSpace(n.Vendor)
+	n.FirmwareFrom = strings.TrimSpace(n.FirmwareFrom)
+	n.FirmwareTo = strings.TrimSpace(n.FirmwareTo)
+	if n.Kind == "" {
+		n.Kind = DeviceNoteKindNote
+	}
+	if n.Serial == "" {
+		return fmt.Errorf("serial is required")
+	}
+	if len(n.Body) > MaxDeviceNoteLength {
+		return fmt.Errorf("body must be at most %d characters", MaxDeviceNoteLength)
+	}
+	if n.PageCount != nil && *n.PageCount < 0 {
+		return fmt.Errorf("page_count must not be negative")
+	}
+	switch n.Kind {
+	case DeviceNoteKindNote:
+		if n.Body == "" {
+			return fmt.Errorf("body is required for notes")
+		}
+	case DeviceNoteKindPartReplaced:
+		if n.Part == "" {
+			return fmt.Errorf("part is required for part_replaced entries")
+		}
+	case DeviceNoteKindServiceVisit:
+		if n.Body == "" && n.Vendor == "" {
+			return fmt.Errorf("body or vendor is required for service_visit entries")
+		}
+	case DeviceNoteKindFirmwareChange:
+		if n.FirmwareTo == "" {
+			return fmt.Errorf("firmware_to is required for firmware_change entries")
+		}
+	default:
+		return fmt.Errorf("kind must be one of note, part_replaced, service_visit, firmware_change")
+	}
+	if n.Kind != DeviceNoteKindPartReplaced {
+		n.Part = ""
+	}
+	if n.Kind != DeviceNoteKindServiceVisit {
+		n.Vendor = ""
+	}
+	if n.Kind != DeviceNoteKindFirmwareChange {
+		n.FirmwareFrom, n.FirmwareTo = "", ""
+	}
+	return nil
+}
+
+// Summary is a one-line description of the entry for timelines and reports.
+func (n *DeviceNote) Summary() string {
+	var s string
+	switch n.Kind {
+	case DeviceNoteKindPartReplaced:
+		s = "Replaced " + n.Part
+	case DeviceNoteKindServiceVisit:
+		s = "Service visit"
+		if n.Vendor != "" {
+			s += " by " + n.Vendor
+		}
+	case DeviceNoteKindFirmwareChange:
+		if n.FirmwareFrom != "" {
+			s = fmt.Sprintf("Firmware %s -> %s", n.FirmwareFrom, n.FirmwareTo)
+		} else {
+			s = "Firmware changed to " + n.FirmwareTo
+		}
+	default:
+		return n.Body
+	}
+	if n.Body != "" {
+		s += ": " + n.Body
+	}
+	return s
+}
@@ -0,0 +1,78 @@
+package storage
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestDeviceNotes(t *testing.T) {
+	t.Parallel()
+	s, err := NewSQLiteStore(":memory:")
+	if err != nil {
+		t.Fatalf("NewSQLiteStore: %v", err)
+	}
+	defer s.Close()
+	ctx := context.Background()
+	day := func(d int) time.Time { return time.Date(2025, 5, d, 9, 0, 0, 0, time.UTC) }
+
+	if err := s.AddDeviceNote(ctx, &DeviceNote{Serial: "SN1", Kind: DeviceNoteKindPartReplaced, Author: "tech"}); err == nil {
+		t.Fatal("expected part_replaced without a part to be rejected")
+	}
+	if err := s.AddDeviceNote(ctx, &DeviceNote{Serial: "SN1", Kind: "repair", Body: "x", Author: "tech"}); err == nil {
+		t.Fatal("expected unknown kind to be rejected")
+	}
+
+	pages := int64(120000)
+	fuser := &DeviceNote{Serial: "SN1", TenantID: "t1", Kind: DeviceNoteKindPartReplaced, Part: "Fuser RM2-5425",
+		Vendor: "ignored", PageCount: &pages, Author: "tech", OccurredAt: day(2)}
+	note := &DeviceNote{Serial: "SN1", TenantID: "t1", Body: "Tray 2 sticks", Author: "alice", OccurredAt: day(3)}
+	other := &DeviceNote{Serial: "SN2", TenantID: "t2", Kind: DeviceNoteKindFirmwareChange, FirmwareFrom: "1.0", FirmwareTo: "2.0", Author: "bob", OccurredAt: day(1)}
+	for _, n := range []*DeviceNote{fuser, note, other} {
+		if err := s.AddDeviceNote(ctx, n); err != nil {
+			t.Fatalf("AddDeviceNote: %v", err)
+		}
+	}
+	if note.Kind != DeviceNoteKindNote || fuser.Vendor != "" {
+		t.Fatalf("expected kind defaulted and unrelated fields cleared: %+v %+v", note, fuser)
+	}
+
+	got, err := s.GetDeviceNote(ctx, fuser.ID)
+	if err != nil || got == nil || got.Part != "Fuser RM2-5425" || got.PageCount == nil || *got.PageCount != pages || !got.OccurredAt.Equal(day(2)) {
+		t.Fatalf("GetDeviceNote: %+v, %v", got, err)
+	}
+	if got.Summary() != "Replaced Fuser RM2-5425" {
+		t.Fatalf("Summary = %q", got.Summary())
+	}
+
+	list, err := s.ListDeviceNotes(ctx, DeviceNoteFilter{Serial: "SN1"})
+	if err != nil || len(list) != 2 || list[0].ID != note.ID {
+		t.Fatalf("expected SN1 notes newest first, got %+v, %v", list, err)
+	}
+	list, _ = s.ListDeviceNotes(ctx, DeviceNoteFilter{TenantIDs: []string{"t2"}})
+	if len(list) != 1 || list[0].ID != other.ID {
+		t.Fatalf("tenant filter: %+v", list)
+	}
+	list, _ = s.ListDeviceNotes(ctx, DeviceNoteFilter{Kinds: []string{DeviceNoteKindPartReplaced, DeviceNoteKindFirmwareChange}, Since: day(2)})
+	if len(list) != 1 || list[0].ID != fuser.ID {
+		t.Fatalf("kind/since filter: %+v", list)
+	}
+
+	note.Body = "Tray 2 roller replaced instead"
+	if err := s.UpdateDeviceNote(ctx, note); err != nil {
+		t.Fatalf("UpdateDeviceNote: %v", err)
+	}
+	if got, _ := s.GetDeviceNote(ctx, note.ID); got.Body != note.Body || got.Author != "alice" {
+		t.Fatalf("update not applied: %+v", got)
+	}
+
+	if err := s.DeleteDeviceNote(ctx, note.ID); err != nil {
+		t.Fatalf("DeleteDeviceNote: %v", err)
+	}
+	if got, err := s.GetDeviceNote(ctx, note.ID); err != nil || got != nil {
+		t.Fatalf("expected deleted note to be gone, got %+v, %v", got, err)
+	}
+	if err := s.DeleteDeviceNote(ctx, note.ID); err == nil {
+		t.Fatal("expected deleting a missing note to fail")
+	}
+}
@@ -0,0 +1,57 @@
+package storage
+
+import (
+	"context"
+	"testing"
+
+	"printmaster/server/devicestatus"
+)
+
+func TestDeviceStatusRulesReplace(t *testing.T) {
+	t.Parallel()
+	s, err := NewSQLiteStore(":memory:")
+	if err != nil {
+		t.Fatalf("NewSQLiteStore: %v", err)
+	}
+	defer s.Close()
+
+	ctx := context.Background()
+
+	rules, err := s.ListDeviceStatusRules(ctx)
+	if err != nil {
+		t.Fatalf("ListDeviceStatusRules (empty): %v", err)
+	}
+	if len(rules) != 0 {
+		t.Fatalf("expected no rules, got %d", len(rules))
+	}
+
+	want := []devicestatus.Rule{
+		{Vendor: "acme", Pattern: "toner low", State: devicestatus.StateMaintenance, Description: "ACME quirk"},
+		{Pattern: "door open", State: devicestatus.StateWarning},
+	}
+	if err := s.ReplaceDeviceStatusRules(ctx, want); err != nil {
+		t.Fatalf("ReplaceDeviceStatusRules: %v", err)
+	}
+	rules, err = s.ListDeviceStatusRules(ctx)
+	if err != nil {
+		t.Fatalf("ListDeviceStatusRules: %v", err)
+	}
+	if len(rules) != 2 {
+		t.Fatalf("expected 2 rules, got %d", len(rules))
+	}
+	for i := range want {
+		got := rules[i]
+		if got.ID == 0 || got.Vendor != want[i].Vendor || got.Pattern != want[i].Pattern ||
+			got.State != want[i].State || got.Description != want[i].Description {
+			t.Fatalf("rule %d = %+v, want %+v", i, got, want[i])
+		}
+	}
+
+	if err := s.ReplaceDeviceStatusRules(ctx, want[1:]); err != nil {
+		t.Fatalf("ReplaceDeviceStatusRules (shrink): %v", err)
+	}
+	rules, _ = s.ListDeviceStatusRules(ctx)
+	if len(rules) != 1 || rules[0].Pattern != "door open" {
+		t.Fatalf("unexpected rules after replace: %+v", rules)
+	}
+}
@@ -0,0 +1,51 @@
+package storage
+
+import (
+	"context"
+	"testing"
+)
+
+func TestFeatureFlagsUpsertListDelete(t *testing.T) {
+	t.Parallel()
+	s, err := NewSQLiteStore(":memory:")
+	if err != nil {
+		t.Fatalf("NewSQLiteStore: %v", err)
+	}
+	defer s.Close()
+	ctx := context.Background()
+
+	if err := s.SetFeatureFlag(ctx, &FeatureFlag{Key: "remote_proxy", Enabled: false, UpdatedBy: "admin"}); err != nil {
+		t.Fatalf("SetFeatureFlag global: %v", err)
+	}
+	if err := s.SetFeatureFlag(ctx, &FeatureFlag{TenantID: "t1", Key: "remote_proxy", Enabled: true}); err != nil {
+		t.Fatalf("SetFeatureFlag tenant: %v", err)
+	}
+	if err := s.SetFeatureFlag(ctx, &FeatureFlag{TenantID: "t1", Key: "remote_proxy", Enabled: false}); err != nil {
+		t.Fatalf("SetFeatureFlag update: %v", err)
+	}
+
+	global, err := s.ListFeatureFlags(ctx, "")
+	if err != nil {
+		t.Fatalf("ListFeatureFlags global: %v", err)
+	}
+	if len(global) != 1 || global[0].Enabled || global[0].UpdatedBy != "admin" {
+		t.Fatalf("unexpected global flags: %+v", global)
+	}
+	tenant, err := s.ListFeatureFlags(ctx, "t1")
+	if err != nil {
+		t.Fatalf("ListFeatureFlags tenant: %v", err)
+	}
+	if len(tenant) != 1 || tenant[0].Enabled || tenant[0].UpdatedBy != "system" {
+		t.Fatalf("unexpected tenant flags: %+v", tenant)
+	}
+
+	if err := s.DeleteFeatureFlag(ctx, "t1", "remote_proxy"); err != nil {
+		t.Fatalf("DeleteFeatureFlag: %v", err)
+	}
+	if tenant, _ = s.ListFeatureFlags(ctx, "t1"); len(tenant) != 0 {
+		t.Fatalf("tenant flag not deleted: %+v", tenant)
+	}
+	if global, _ = s.ListFeatureFlags(ctx, ""); len(global) != 1 {
+		t.Fatalf("global flag should remain: %+v", global)
+	}
+}
@@ -0,0 +1,42 @@
+package storage
+
+import (
+	"context"
+	"errors"
+)
+
+// HealthChecker is implemented by stores that can report database health for
+// the /readyz probe.
+type HealthChecker interface {
+	// Ping checks that the database is reachable
+	Ping(ctx context.Context) error
+
+	// SchemaVersion returns the applied and the expected schema versions
+	SchemaVersion(ctx context.Context) (applied, expected int, err error)
+}
+
+// Ping checks that the database is reachable.
+func (s *BaseStore) Ping(ctx context.Context) error {
+	if s.db == nil {
+		return errors.New("database not open")
+	}
+	return s.db.PingContext(ctx)
+}
+
+func (s *BaseStore) appliedSchemaVersion(ctx context.Context) (int, error) {
+	var version int
+	err := s.queryRowContext(ctx, "SELECT COALESCE(MAX(version), 0) FROM schema_version").Scan(&version)
+	return version, err
+}
+
+// SchemaVersion returns the applied and the expected schema versions.
+func (s *SQLiteStore) SchemaVersion(ctx context.Context) (int, int, error) {
+	applied, err := s.appliedSchemaVersion(ctx)
+	return applied, schemaVersion, err
+}
+
+// SchemaVersion returns the applied and the expected schema versions.
+func (s *PostgresStore) SchemaVersion(ctx context.Context) (int, int, error) {
+	applied, err := s.appliedSchemaVersion(ctx)
+	return applied, pgSchemaVersion, err
+}
@@ -10,6 +10,7 @@ import (
 	"os"
 	"path/filepath"
 	"runtime"
+	"strings"
 	"time"
 )
 
@@ -151,3 +152,11 @@ func decodeMetadata(raw sql.NullString) map[string]any {
 	}
 	return result
 }
+
+// placeholderList returns n comma-separated ? placeholders for IN clauses.
+func placeholderList(n int) string {
+	if n <= 0 {
+		return ""
+	}
+	return strings.Repeat("?,", n-1) + "?"
+}
@@ -0,0 +1,122 @@
+package storage
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Incident statuses. An incident moves through open, investigating and
+// waiting_parts in any order until it is resolved; resolved incidents can be
+// reopened.
+const (
+	IncidentStatusOpen          = "open"
+	IncidentStatusInvestigating = "investigating"
+	IncidentStatusWaitingParts  = "waiting_parts"
+	IncidentStatusResolved      = "resolved"
+)
+
+// Incident sources: raised by hand or promoted from persistent alerts.
+const (
+	IncidentSourceManual        = "manual"
+	IncidentSourceDeviceOffline = "device_offline"
+	IncidentSourceRepeatedJams  = "repeated_jams"
+)
+
+// Incident note kinds. Status and assignment changes are recorded alongside
+// user notes so the note list doubles as the incident's history.
+const (
+	IncidentNoteKindNote       = "note"
+	IncidentNoteKindStatus     = "status_change"
+	IncidentNoteKindAssignment = "assignment"
+	IncidentNoteKindAlerts     = "alerts_linked"
+)
+
+// MaxIncidentNoteLength caps the text of an incident note or description.
+const MaxIncidentNoteLength = 10000
+
+// Incident tracks a printer problem through to resolution. Incidents are
+// separate from alerts: alerts come and go with device state, while an
+// incident stays open until someone resolves it.
+type Incident struct {
+	ID           int64      `json:"id"`
+	TenantID     string     `json:"tenant_id,omitempty"`
+	Title        string     `json:"title"`
+	Description  string     `json:"description,omitempty"`
+	Severity     string     `json:"severity"`
+	Status       string     `json:"status"`
+	Assignee     string     `json:"assignee,omitempty"`
+	DeviceSerial string     `json:"device_serial,omitempty"`
+	AgentID      string     `json:"agent_id,omitempty"`
+	Source       string     `json:"source"`
+	Key          string     `json:"key,omitempty"` // Deduplicates promoted incidents, e.g. repeated_jams:SN123
+	CreatedBy    string     `json:"created_by,omitempty"`
+	OpenedAt     time.Time  `json:"opened_at"`
+	UpdatedAt    time.Time  `json:"updated_at"`
+	ResolvedAt   *time.Time `json:"resolved_at,omitempty"`
+	AlertIDs     []int64    `json:"alert_ids"`
+}
+
+// IncidentNote is a comment or history entry on an incident.
+type IncidentNote struct {
+	ID         int64     `json:"id"`
+	IncidentID int64     `json:"incident_id"`
+	Kind       string    `json:"kind"`
+	Body       string    `json:"body"`
+	Author     string    `json:"author"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// IncidentFilter narrows ListIncidents. Zero values match everything.
+type IncidentFilter struct {
+	TenantIDs    []string // Empty = all tenants
+	Statuses     []string
+	OpenOnly     bool // Exclude resolved incidents
+	Assignee     string
+	DeviceSerial string
+	Key          string
+	Limit        int
+}
+
+// IsIncidentStatus reports whether status is a known incident status.
+func IsIncidentStatus(status string) bool {
+	switch status {
+	case IncidentStatusOpen, IncidentStatusInvestigating, IncidentStatusWaitingParts, IncidentStatusResolved:
+		return true
+	}
+	return false
+}
+
+// Validate normalizes an incident and checks its required fields.
+func (i *Incident) Validate() error {
+	i.Title = strings.TrimSpace(i.Title)
+	i.Description = strings.TrimSpace(i.Description)
+	i.Assignee = strings.TrimSpace(i.Assignee)
+	i.DeviceSerial = strings.TrimSpace(i.DeviceSerial)
+	i.Status = strings.ToLower(strings.TrimSpace(i.Status))
+	i.Severity = strings.ToLower(strings.TrimSpace(i.Severity))
+	if i.Status == "" {
+		i.Status = IncidentStatusOpen
+	}
+	if i.Severity == "" {
+		i.Severity = AlertSeverityWarning
+	}
+	if i.Source == "" {
+		i.Source = IncidentSourceManual
+	}
+	if i.Title == "" {
+		return fmt.Errorf("title is required")
+	}
+	if !IsIncidentStatus(i.Status) {
+		return fmt.Errorf("unknown status %q", i.Status)
+	}
+	switch i.Severity {
+	case AlertSeverityCritical, AlertSeverityWarning, AlertSeverityInfo:
+	default:
+		return fmt.Errorf("unknown severity %q", i.Severity)
+	}
+	if len(i.Description) > MaxIncidentNoteLength {
+		return fmt.Errorf("description must be at most %d characters", MaxIncidentNoteLength)
+	}
+	return nil
+}
@@ -0,0 +1,84 @@
+package storage
+
+import (
+	"context"
+	"testing"
+)
+
+func TestIncidents(t *testing.T) {
+	t.Parallel()
+	s, err := NewSQLiteStore(":memory:")
+	if err != nil {
+		t.Fatalf("NewSQLiteStore: %v", err)
+	}
+	defer s.Close()
+	ctx := context.Background()
+
+	if err := s.CreateIncident(ctx, &Incident{Title: " "}); err == nil {
+		t.Fatal("expected incident without a title to be rejected")
+	}
+	if err := s.CreateIncident(ctx, &Incident{Title: "x", Status: "closed"}); err == nil {
+		t.Fatal("expected unknown status to be rejected")
+	}
+
+	jam := &Incident{TenantID: "t1", Title: "Repeated Paper Jams: SN1", DeviceSerial: "SN1",
+		Source: IncidentSourceRepeatedJams, Key: "repeated_jams:SN1", AlertIDs: []int64{3, 4, 3}}
+	if err := s.CreateIncident(ctx, jam); err != nil {
+		t.Fatalf("CreateIncident: %v", err)
+	}
+	manual := &Incident{TenantID: "t2", Title: "Lobby printer smells of smoke", Severity: AlertSeverityCritical, CreatedBy: "alice"}
+	if err := s.CreateIncident(ctx, manual); err != nil {
+		t.Fatalf("CreateIncident: %v", err)
+	}
+	if manual.Status != IncidentStatusOpen || manual.Source != IncidentSourceManual {
+		t.Fatalf("defaults not applied: %+v", manual)
+	}
+
+	if err := s.LinkIncidentAlerts(ctx, jam.ID, []int64{4, 5}); err != nil {
+		t.Fatalf("LinkIncidentAlerts: %v", err)
+	}
+	got, err := s.GetIncident(ctx, jam.ID)
+	if err != nil || got == nil {
+		t.Fatalf("GetIncident: %v", err)
+	}
+	if len(got.AlertIDs) != 3 || got.AlertIDs[0] != 3 || got.AlertIDs[2] != 5 || got.Key != "repeated_jams:SN1" {
+		t.Fatalf("unexpected incident: %+v", got)
+	}
+
+	got.Status = IncidentStatusWaitingParts
+	got.Assignee = "bob"
+	if err := s.UpdateIncident(ctx, got); err != nil {
+		t.Fatalf("UpdateIncident: %v", err)
+	}
+	if list, _ := s.ListIncidents(ctx, IncidentFilter{Assignee: "bob", Statuses: []string{IncidentStatusWaitingParts}}); len(list) != 1 || list[0].ID != jam.ID {
+		t.Fatalf("expected the jam incident by assignee and status, got %+v", list)
+	}
+
+	got.Status = IncidentStatusResolved
+	if err := s.UpdateIncident(ctx, got); err != nil || got.ResolvedAt == nil {
+		t.Fatalf("resolve: %v %+v", err, got)
+	}
+	if list, _ := s.ListIncidents(ctx, IncidentFilter{OpenOnly: true}); len(list) != 1 || list[0].ID != manual.ID {
+		t.Fatalf("expected only the manual incident open, got %+v", list)
+	}
+	got.Status = IncidentStatusOpen
+	if err := s.UpdateIncident(ctx, got); err != nil || got.ResolvedAt != nil {
+		t.Fatalf("reopen: %v %+v", err, got)
+	}
+	if list, _ := s.ListIncidents(ctx, IncidentFilter{TenantIDs: []string{"t2"}}); len(list) != 1 || list[0].ID != manual.ID || len(list[0].AlertIDs) != 0 {
+		t.Fatalf("tenant filter: %+v", list)
+	}
+
+	if err := s.AddIncidentNote(ctx, &IncidentNote{IncidentID: jam.ID, Author: "bob"}); err == nil {
+		t.Fatal("expected empty note to be rejected")
+	}
+	for _, body := range []string{"Ordered pickup roller", "Roller fitted"} {
+		if err := s.AddIncidentNote(ctx, &IncidentNote{IncidentID: jam.ID, Body: body, Author: "bob"}); err != nil {
+			t.Fatalf("AddIncidentNote: %v", err)
+		}
+	}
+	notes, err := s.ListIncidentNotes(ctx, jam.ID)
+	if err != nil || len(notes) != 2 || notes[0].Body != "Ordered pickup roller" || notes[1].Kind != IncidentNoteKindNote {
+		t.Fatalf("ListIncidentNotes: %v %+v", err, notes)
+	}
+}
@@ -0,0 +1,86 @@
+package storage
+
+import (
+	"errors"
+	"time"
+
+	"printmaster/common/meterread"
+)
+
+// MeterRead is a certified meter read received from an agent. The embedded
+// read is stored exactly as signed; TenantID and ReceivedAt are recorded by
+// the server at ingestion.
+type MeterRead struct {
+	meterread.Read
+	TenantID   string    `json:"tenant_id,omitempty"`
+	ReceivedAt time.Time `json:"received_at"`
+}
+
+// AgentSigningKey is a public key an agent signs meter reads with. Keys are
+// never removed, so reads signed before an agent's key changed still verify.
+type AgentSigningKey struct {
+	AgentID   string    `json:"agent_id"`
+	KeyID     string    `json:"key_id"`
+	PublicKey string    `json:"public_key"` // base64, see meterread.EncodePublicKey
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// ErrMeterReadExists is returned when storing a meter read whose ID is
+// already present.
+var ErrMeterReadExists = errors.New("meter read already exists")
+
+// MeterReadFilter narrows ListMeterReads results.
+type MeterReadFilter struct {
+	Serial    string
+	TenantIDs []string  // Empty = all tenants
+	Period    string    // YYYY-MM, empty = all periods
+	Before    time.Time // Only reads taken before this instant (zero = no bound)
+	Limit     int
+}
+
+// Meter read anomaly kinds raised during reconciliation.
+const (
+	MeterAnomalyCounterReset    = "counter_reset"    // Counter dropped to near zero (board/formatter replacement)
+	MeterAnomalyCounterRollback = "counter_rollback" // Counter went backwards without a reset
+	MeterAnomalySerialMismatch  = "serial_mismatch"  // Device reported a different serial at read time
+	MeterAnomalyUsageSpike      = "usage_spike"      // Delta far above the device's usual volume
+	MeterAnomalyMissedPeriod    = "missed_period"    // Previous read is more than one period old
+)
+
+// Meter read anomaly review states.
+const (
+	MeterAnomalyOpen     = "open"     // Awaiting review; the read is held out of reconciled totals
+	MeterAnomalyAccepted = "accepted" // Reviewed; the computed delta stands
+	MeterAnomalyAdjusted = "adjusted" // Reviewed; the delta was replaced by AdjustedMono/AdjustedColor
+)
+
+// ErrMeterAnomalyNotFound is returned when resolving an unknown anomaly.
+var ErrMeterAnomalyNotFound = errors.New("meter read anomaly not found")
+
+// MeterReadAnomaly flags a reconciled read for manual review. Each read has
+// at most one anomaly per kind.
+type MeterReadAnomaly struct {
+	ID            int64     `json:"id"`
+	ReadID        string    `json:"read_id"`
+	Serial        string    `json:"serial"`
+	TenantID      string    `json:"tenant_id,omitempty"`
+	Period        string    `json:"period"`
+	Kind          string    `json:"kind"`
+	Details       string    `json:"details,omitempty"`
+	Status        string    `json:"status"`
+	AdjustedMono  int64     `json:"adjusted_mono,omitempty"`
+	AdjustedColor int64     `json:"adjusted_color,omitempty"`
+	Note          string    `json:"note,omitempty"`
+	ResolvedBy    string    `json:"resolved_by,omitempty"`
+	ResolvedAt    time.Time `json:"resolved_at,omitzero"`
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+// MeterReadAnomalyFilter narrows ListMeterReadAnomalies results.
+type MeterReadAnomalyFilter struct {
+	ID        int64    // Zero = any
+	TenantIDs []string // Empty = all tenants
+	Period    string
+	ReadIDs   []string
+	Status    string // Empty = any status
+}
@@ -0,0 +1,101 @@
+package storage
+
+import (
+	"context"
+	"crypto/ed25519"
+	"errors"
+	"testing"
+	"time"
+
+	"printmaster/common/meterread"
+)
+
+func TestMeterReadsAndAnomalies(t *testing.T) {
+	t.Parallel()
+	s, err := NewSQLiteStore(":memory:")
+	if err != nil {
+		t.Fatalf("NewSQLiteStore: %v", err)
+	}
+	defer s.Close()
+	ctx := context.Background()
+
+	read := &MeterRead{
+		Read: meterread.Read{
+			ID:           "mr-1",
+			Serial:       "SN1",
+			AgentID:      "agent-1",
+			Period:       "2026-09",
+			Trigger:      meterread.TriggerScheduled,
+			ReadAt:       time.Date(2026, 9, 1, 2, 0, 0, 0, time.UTC),
+			PageCount:    1200,
+			MonoPages:    1000,
+			ColorPages:   200,
+			Confirmation: meterread.Confirmation{ReportedSerial: "SN1", SerialMatch: true},
+			RawOIDs:      []meterread.OIDValue{{OID: "1.3.6.1.2.1.43.10.2.1.4.1.1", Type: "Counter32", Value: "1200"}},
+		},
+		TenantID: "t1",
+	}
+	pub, key, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	read.Sign(key)
+	if err := s.AddMeterRead(ctx, read); err != nil {
+		t.Fatalf("AddMeterRead: %v", err)
+	}
+	if err := s.AddMeterRead(ctx, read); !errors.Is(err, ErrMeterReadExists) {
+		t.Fatalf("duplicate AddMeterRead = %v, want ErrMeterReadExists", err)
+	}
+
+	reads, err := s.ListMeterReads(ctx, MeterReadFilter{TenantIDs: []string{"t1"}, Period: "2026-09"})
+	if err != nil || len(reads) != 1 {
+		t.Fatalf("ListMeterReads = %v, %v", reads, err)
+	}
+	if err := reads[0].Verify(pub); err != nil {
+		t.Fatalf("stored read no longer verifies: %v", err)
+	}
+
+	signingKey := &AgentSigningKey{AgentID: "agent-1", KeyID: meterread.KeyID(pub), PublicKey: meterread.EncodePublicKey(pub)}
+	for i := 0; i < 2; i++ {
+		if err := s.AddAgentSigningKey(ctx, signingKey); err != nil {
+			t.Fatalf("AddAgentSigningKey: %v", err)
+		}
+	}
+	keys, err := s.ListAgentSigningKeys(ctx, "agent-1")
+	if err != nil || len(keys) != 1 || keys[0].KeyID != reads[0].KeyID {
+		t.Fatalf("ListAgentSigningKeys = %+v, %v", keys, err)
+	}
+
+	anomaly := &MeterReadAnomaly{ReadID: "mr-1", Serial: "SN1", TenantID: "t1", Period: "2026-09", Kind: MeterAnomalyCounterRollback}
+	for i := 0; i < 2; i++ {
+		if err := s.RecordMeterReadAnomaly(ctx, anomaly); err != nil {
+			t.Fatalf("RecordMeterReadAnomaly: %v", err)
+		}
+	}
+	open, err := s.ListMeterReadAnomalies(ctx, MeterReadAnomalyFilter{Status: MeterAnomalyOpen})
+	if err != nil || len(open) != 1 {
+		t.Fatalf("ListMeterReadAnomalies = %v, %v", open, err)
+	}
+
+	open[0].Status = MeterAnomalyAdjusted
+	open[0].AdjustedMono = 50
+	open[0].ResolvedBy = "reviewer"
+	if err := s.ResolveMeterReadAnomaly(ctx, open[0]); err != nil {
+		t.Fatalf("ResolveMeterReadAnomaly: %v", err)
+	}
+	// Re-recording after review must not reopen the anomaly
+	if err := s.RecordMeterReadAnomaly(ctx, anomaly); err != nil {
+		t.Fatalf("RecordMeterReadAnomaly: %v", err)
+	}
+	got, err := s.ListMeterReadAnomalies(ctx, MeterReadAnomalyFilter{ID: open[0].ID})
+	if err != nil || len(got) != 1 {
+		t.Fatalf("ListMeterReadAnomalies by id = %v, %v", got, err)
+	}
+	if got[0].Status != MeterAnomalyAdjusted || got[0].AdjustedMono != 50 || got[0].ResolvedBy != "reviewer" || got[0].ResolvedAt.IsZero() {
+		t.Fatalf("unexpected resolved anomaly: %+v", got[0])
+	}
+
+	if err := s.ResolveMeterReadAnomaly(ctx, &MeterReadAnomaly{ID: 999, Status: MeterAnomalyAccepted}); !errors.Is(err, ErrMeterAnomalyNotFound) {
+		t.Fatalf("resolve unknown = %v, want ErrMeterAnomalyNotFound", err)
+	}
+}
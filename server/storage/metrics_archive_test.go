@@ -0,0 +1,88 @@
+package storage
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestMetricsArchiveStore(t *testing.T) {
+	t.Parallel()
+	s, err := NewSQLiteStore(":memory:")
+	if err != nil {
+		t.Fatalf("NewSQLiteStore: %v", err)
+	}
+	defer s.Close()
+	ctx := context.Background()
+
+	if oldest, err := s.GetOldestMetricsTimestamp(ctx); err != nil || !oldest.IsZero() {
+		t.Fatalf("GetOldestMetricsTimestamp on empty store = %v, %v", oldest, err)
+	}
+
+	now := time.Now().UTC()
+	if err := s.RegisterAgent(ctx, &Agent{AgentID: "agent-1", Hostname: "pc", Token: "t", Status: "active", RegisteredAt: now, LastSeen: now}); err != nil {
+		t.Fatalf("RegisterAgent: %v", err)
+	}
+	dev := &Device{AgentID: "agent-1"}
+	dev.Serial = "SER1"
+	if err := s.UpsertDevice(ctx, dev); err != nil {
+		t.Fatalf("UpsertDevice: %v", err)
+	}
+	start := time.Date(2021, 3, 1, 0, 0, 0, 0, time.UTC)
+	end := start.AddDate(0, 1, 0)
+	for i := 0; i < 3; i++ {
+		m := &MetricsSnapshot{Serial: "SER1", AgentID: "agent-1", Timestamp: start.Add(time.Duration(i) * time.Hour), PageCount: 100 + i}
+		if err := s.SaveMetrics(ctx, m); err != nil {
+			t.Fatalf("SaveMetrics: %v", err)
+		}
+	}
+	if oldest, err := s.GetOldestMetricsTimestamp(ctx); err != nil || !oldest.Equal(start) {
+		t.Fatalf("GetOldestMetricsTimestamp = %v, %v", oldest, err)
+	}
+
+	all, err := s.ListMetricsInRange(ctx, start, end)
+	if err != nil || len(all) != 3 {
+		t.Fatalf("ListMetricsInRange = %d rows, %v", len(all), err)
+	}
+
+	// Rows outside the range are refused and nothing changes
+	if _, err := s.ReplaceMetricsInRange(ctx, start, end, []*MetricsSnapshot{{Serial: "SER1", AgentID: "agent-1", Timestamp: end}}); err == nil {
+		t.Error("ReplaceMetricsInRange accepted a row outside the range")
+	}
+	removed, err := s.ReplaceMetricsInRange(ctx, start, end, all[2:])
+	if err != nil || removed != 3 {
+		t.Fatalf("ReplaceMetricsInRange = %d, %v", removed, err)
+	}
+	if left, _ := s.ListMetricsInRange(ctx, start, end); len(left) != 1 || left[0].PageCount != 102 {
+		t.Fatalf("rows after replace = %+v", left)
+	}
+
+	restore := append(all, &MetricsSnapshot{Serial: "GONE", AgentID: "agent-1", Timestamp: start.Add(time.Minute)})
+	inserted, skipped, err := s.InsertMissingMetrics(ctx, restore)
+	if err != nil || inserted != 2 || skipped != 1 {
+		t.Fatalf("InsertMissingMetrics = %d, %d, %v", inserted, skipped, err)
+	}
+
+	a := &MetricsArchive{PeriodStart: start, PeriodEnd: end, Path: "/tmp/metrics-2021-03.parquet", RowCount: 3, DeviceCount: 1, SizeBytes: 512, SHA256: "abc"}
+	if err := s.CreateMetricsArchive(ctx, a); err != nil || a.ID == 0 {
+		t.Fatalf("CreateMetricsArchive: %v (id %d)", err, a.ID)
+	}
+	until := now.Add(time.Hour)
+	a.SummaryRows, a.RestoredAt, a.RestoredUntil = 1, &now, &until
+	if err := s.UpdateMetricsArchive(ctx, a); err != nil {
+		t.Fatalf("UpdateMetricsArchive: %v", err)
+	}
+	got, err := s.GetMetricsArchive(ctx, a.ID)
+	if err != nil || got == nil || got.SummaryRows != 1 || got.RestoredUntil == nil || !got.PeriodStart.Equal(start) {
+		t.Fatalf("GetMetricsArchive = %+v, %v", got, err)
+	}
+	if list, err := s.ListMetricsArchives(ctx); err != nil || len(list) != 1 {
+		t.Fatalf("ListMetricsArchives = %d, %v", len(list), err)
+	}
+	if err := s.DeleteMetricsArchive(ctx, a.ID); err != nil {
+		t.Fatalf("DeleteMetricsArchive: %v", err)
+	}
+	if got, err := s.GetMetricsArchive(ctx, a.ID); err != nil || got != nil {
+		t.Fatalf("GetMetricsArchive after delete = %+v, %v", got, err)
+	}
+}
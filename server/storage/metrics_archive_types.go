@@ -0,0 +1,22 @@
+package storage
+
+import "time"
+
+// MetricsArchive records one period of metrics moved to a cold storage file.
+// Full-resolution rows live in the file; metrics_history keeps one summary
+// row per device and day for the period. Restoring copies the rows back until
+// RestoredUntil, after which the period is thinned again.
+type MetricsArchive struct {
+	ID            int64      `json:"id"`
+	PeriodStart   time.Time  `json:"period_start"`
+	PeriodEnd     time.Time  `json:"period_end"` // Exclusive
+	Path          string     `json:"path"`
+	RowCount      int64      `json:"row_count"`    // Rows in the file
+	SummaryRows   int64      `json:"summary_rows"` // Rows kept in metrics_history
+	DeviceCount   int        `json:"device_count"`
+	SizeBytes     int64      `json:"size_bytes"`
+	SHA256        string     `json:"sha256"`
+	CreatedAt     time.Time  `json:"created_at"`
+	RestoredAt    *time.Time `json:"restored_at,omitempty"`
+	RestoredUntil *time.Time `json:"restored_until,omitempty"`
+}
@@ -0,0 +1,30 @@
+-- Device approval workflow
+-- Newly discovered devices can be held for operator review before they
+-- appear in reports and alerting. Policies are stored per tenant.
+
+CREATE TABLE IF NOT EXISTS device_approvals (
+    serial TEXT PRIMARY KEY,
+    tenant_id TEXT,
+    agent_id TEXT NOT NULL,
+    status TEXT NOT NULL DEFAULT 'pending',
+    manufacturer TEXT,
+    model TEXT,
+    ip TEXT,
+    reason TEXT,
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    reviewed_at DATETIME,
+    reviewed_by TEXT,
+    notes TEXT
+);
+
+CREATE INDEX IF NOT EXISTS idx_device_approvals_status ON device_approvals(status);
+CREATE INDEX IF NOT EXISTS idx_device_approvals_tenant ON device_approvals(tenant_id);
+
+CREATE TABLE IF NOT EXISTS device_approval_policies (
+    tenant_id TEXT PRIMARY KEY,
+    enabled INTEGER NOT NULL DEFAULT 0,
+    auto_approve_manufacturers TEXT,
+    auto_approve_models TEXT,
+    updated_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_by TEXT
+);
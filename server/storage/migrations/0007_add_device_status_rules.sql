@@ -0,0 +1,14 @@
+-- Admin-defined device status normalization rules
+-- Raw printer status messages are mapped to canonical states (ready, warning,
+-- error, offline, maintenance). Custom rules are evaluated in position order
+-- before the built-in defaults.
+
+CREATE TABLE IF NOT EXISTS device_status_rules (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    position INTEGER NOT NULL DEFAULT 0,
+    vendor TEXT,
+    pattern TEXT NOT NULL,
+    state TEXT NOT NULL,
+    description TEXT,
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
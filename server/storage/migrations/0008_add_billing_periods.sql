@@ -0,0 +1,24 @@
+-- Finalized per-tenant monthly billing counters
+-- Open periods are computed live from device metrics; once a period is
+-- finalized its counters are written here and never recomputed, so exports
+-- consumed by billing systems don't change retroactively.
+
+CREATE TABLE IF NOT EXISTS billing_periods (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    tenant_id TEXT NOT NULL,
+    period TEXT NOT NULL,
+    period_start DATETIME NOT NULL,
+    period_end DATETIME NOT NULL,
+    devices_managed INTEGER NOT NULL DEFAULT 0,
+    pages_mono BIGINT NOT NULL DEFAULT 0,
+    pages_color BIGINT NOT NULL DEFAULT 0,
+    pages_total BIGINT NOT NULL DEFAULT 0,
+    agents_online INTEGER NOT NULL DEFAULT 0,
+    agents_total INTEGER NOT NULL DEFAULT 0,
+    computed_at DATETIME NOT NULL,
+    finalized_at DATETIME NOT NULL,
+    finalized_by TEXT,
+    schema_version INTEGER NOT NULL DEFAULT 1,
+    UNIQUE(tenant_id, period)
+);
+CREATE INDEX IF NOT EXISTS idx_billing_periods_period ON billing_periods(period);
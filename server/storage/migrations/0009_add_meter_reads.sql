@@ -0,0 +1,58 @@
+-- Certified meter reads and reconciliation anomalies
+-- Agents upload billing-grade counter reads signed with their own ed25519
+-- key; the server verifies the signature against the keys the agent
+-- registered (old keys are kept so queued reads still verify), stores the read unchanged and reconciles consecutive reads per
+-- device. Suspicious deltas (counter resets, rollbacks, serial mismatches,
+-- spikes, gaps) are recorded as anomalies and held for manual review.
+
+CREATE TABLE IF NOT EXISTS meter_reads (
+    id TEXT PRIMARY KEY,
+    serial TEXT NOT NULL,
+    agent_id TEXT NOT NULL,
+    tenant_id TEXT,
+    ip TEXT,
+    manufacturer TEXT,
+    model TEXT,
+    period TEXT NOT NULL,
+    trigger TEXT NOT NULL,
+    read_at DATETIME NOT NULL,
+    page_count BIGINT NOT NULL DEFAULT 0,
+    mono_pages BIGINT NOT NULL DEFAULT 0,
+    color_pages BIGINT NOT NULL DEFAULT 0,
+    scan_count BIGINT NOT NULL DEFAULT 0,
+    confirmation TEXT,
+    raw_oids TEXT,
+    signature_alg TEXT,
+    key_id TEXT,
+    signature TEXT,
+    received_at DATETIME NOT NULL
+);
+CREATE INDEX IF NOT EXISTS idx_meter_reads_serial ON meter_reads(serial, read_at);
+CREATE INDEX IF NOT EXISTS idx_meter_reads_period ON meter_reads(period, tenant_id);
+
+CREATE TABLE IF NOT EXISTS agent_signing_keys (
+    agent_id TEXT NOT NULL,
+    key_id TEXT NOT NULL,
+    public_key TEXT NOT NULL,
+    created_at DATETIME NOT NULL,
+    PRIMARY KEY (agent_id, key_id)
+);
+
+CREATE TABLE IF NOT EXISTS meter_read_anomalies (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    read_id TEXT NOT NULL,
+    serial TEXT NOT NULL,
+    tenant_id TEXT,
+    period TEXT NOT NULL,
+    kind TEXT NOT NULL,
+    details TEXT,
+    status TEXT NOT NULL DEFAULT 'open',
+    adjusted_mono BIGINT NOT NULL DEFAULT 0,
+    adjusted_color BIGINT NOT NULL DEFAULT 0,
+    note TEXT,
+    resolved_by TEXT,
+    resolved_at DATETIME,
+    created_at DATETIME NOT NULL,
+    UNIQUE(read_id, kind)
+);
+CREATE INDEX IF NOT EXISTS idx_meter_read_anomalies_period ON meter_read_anomalies(period, status);
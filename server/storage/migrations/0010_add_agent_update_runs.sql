@@ -0,0 +1,25 @@
+-- Agent self-update rollout records
+-- Agents report telemetry for every phase of a self-update. The server keeps
+-- one record per agent and run, including the diagnostics (autoupdate log
+-- tail, installer log, environment) attached to failure reports, and clusters
+-- failures by normalized error signature for the rollout dashboard.
+
+CREATE TABLE IF NOT EXISTS agent_update_runs (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    agent_id TEXT NOT NULL,
+    tenant_id TEXT,
+    run_id TEXT NOT NULL,
+    status TEXT NOT NULL,
+    current_version TEXT,
+    target_version TEXT,
+    error_code TEXT,
+    error_message TEXT,
+    error_signature TEXT,
+    diagnostics TEXT,
+    metadata_json TEXT,
+    started_at DATETIME NOT NULL,
+    updated_at DATETIME NOT NULL,
+    UNIQUE(agent_id, run_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_agent_update_runs_status ON agent_update_runs(status, updated_at);
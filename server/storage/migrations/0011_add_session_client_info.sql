@@ -0,0 +1,7 @@
+-- Session client details for the session management UI
+-- last_seen_at is refreshed at most once a minute and drives the optional
+-- idle timeout (security.session_idle_minutes).
+
+ALTER TABLE sessions ADD COLUMN last_seen_at DATETIME;
+ALTER TABLE sessions ADD COLUMN ip_address TEXT;
+ALTER TABLE sessions ADD COLUMN user_agent TEXT;
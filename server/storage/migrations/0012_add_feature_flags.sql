@@ -0,0 +1,12 @@
+-- Per-tenant feature flags (entitlements)
+-- A row with tenant_id '' is the server-wide default; tenant rows override it.
+-- Flags without a row use the built-in default.
+
+CREATE TABLE IF NOT EXISTS feature_flags (
+    tenant_id TEXT NOT NULL DEFAULT '',
+    flag TEXT NOT NULL,
+    enabled INTEGER NOT NULL DEFAULT 0,
+    updated_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_by TEXT,
+    PRIMARY KEY (tenant_id, flag)
+);
@@ -0,0 +1,16 @@
+-- Agent token lifetimes and rotation
+-- token_expires_at is NULL for tokens that never expire (agents that do not
+-- support rotation). previous_token stays valid until previous_token_expires_at
+-- so an agent can finish persisting a rotated token. token_resend_nonce is a
+-- hash of the nonce the agent sent with the rotating heartbeat; only a
+-- heartbeat carrying the same nonce is sent the new token again. Forced
+-- rotations store none and revoke the previous token at once.
+
+ALTER TABLE agents ADD COLUMN token_issued_at DATETIME;
+ALTER TABLE agents ADD COLUMN token_expires_at DATETIME;
+ALTER TABLE agents ADD COLUMN previous_token TEXT;
+ALTER TABLE agents ADD COLUMN previous_token_expires_at DATETIME;
+ALTER TABLE agents ADD COLUMN token_rotation_requested INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE agents ADD COLUMN token_resend_nonce TEXT;
+
+CREATE INDEX IF NOT EXISTS idx_agents_previous_token ON agents(previous_token);
@@ -0,0 +1,15 @@
+-- Cartridge baseline library
+-- Rated page yields per printer model and supply, used by the toner yield
+-- report to compare actual cartridge yields against the manufacturer rating.
+
+CREATE TABLE IF NOT EXISTS yield_baselines (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    position INTEGER NOT NULL DEFAULT 0,
+    printer_model TEXT NOT NULL,
+    supply TEXT NOT NULL,
+    cartridge_model TEXT,
+    supplier TEXT,
+    rated_yield INTEGER NOT NULL,
+    notes TEXT,
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
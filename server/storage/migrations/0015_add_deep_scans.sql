@@ -0,0 +1,57 @@
+-- Server-scheduled deep scans
+-- Schedules, runs and per-agent results (parse captures and diffs against
+-- the previous run) for deep scans orchestrated across agents.
+
+CREATE TABLE IF NOT EXISTS deep_scan_schedules (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    name TEXT NOT NULL,
+    enabled INTEGER NOT NULL DEFAULT 1,
+    agent_ids TEXT,
+    ranges TEXT,
+    include_saved INTEGER NOT NULL DEFAULT 1,
+    frequency TEXT NOT NULL DEFAULT 'daily',
+    day_of_week INTEGER NOT NULL DEFAULT 0,
+    time_of_day TEXT NOT NULL DEFAULT '02:00',
+    timezone TEXT NOT NULL DEFAULT 'UTC',
+    next_run_at DATETIME NOT NULL,
+    last_run_at DATETIME,
+    last_run_id INTEGER,
+    created_by TEXT,
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS deep_scan_runs (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    schedule_id INTEGER,
+    name TEXT,
+    status TEXT NOT NULL,
+    triggered_by TEXT,
+    started_at DATETIME NOT NULL,
+    completed_at DATETIME,
+    agents_total INTEGER NOT NULL DEFAULT 0,
+    agents_completed INTEGER NOT NULL DEFAULT 0,
+    agents_failed INTEGER NOT NULL DEFAULT 0,
+    devices_scanned INTEGER NOT NULL DEFAULT 0,
+    devices_failed INTEGER NOT NULL DEFAULT 0,
+    devices_changed INTEGER NOT NULL DEFAULT 0
+);
+CREATE INDEX IF NOT EXISTS idx_deep_scan_runs_schedule ON deep_scan_runs(schedule_id, started_at);
+
+CREATE TABLE IF NOT EXISTS deep_scan_agent_results (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    run_id INTEGER NOT NULL REFERENCES deep_scan_runs(id) ON DELETE CASCADE,
+    agent_id TEXT NOT NULL,
+    status TEXT NOT NULL,
+    job_id TEXT,
+    total INTEGER NOT NULL DEFAULT 0,
+    done INTEGER NOT NULL DEFAULT 0,
+    failed INTEGER NOT NULL DEFAULT 0,
+    error TEXT,
+    results_json TEXT,
+    diffs_json TEXT,
+    started_at DATETIME,
+    completed_at DATETIME
+);
+CREATE INDEX IF NOT EXISTS idx_deep_scan_agent_results_run ON deep_scan_agent_results(run_id);
+CREATE INDEX IF NOT EXISTS idx_deep_scan_agent_results_agent ON deep_scan_agent_results(agent_id, run_id);
@@ -0,0 +1,22 @@
+-- Tenant share links
+-- Time-limited, read-only links to a filtered device view or a single report
+-- run, bound to one tenant. Tokens and optional passwords are stored hashed.
+
+CREATE TABLE IF NOT EXISTS share_links (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    tenant_id TEXT NOT NULL,
+    kind TEXT NOT NULL,
+    name TEXT,
+    filter_json TEXT,
+    report_run_id INTEGER,
+    token_hash TEXT NOT NULL UNIQUE,
+    password_hash TEXT,
+    expires_at DATETIME NOT NULL,
+    revoked_at DATETIME,
+    revoked_by TEXT,
+    access_count INTEGER NOT NULL DEFAULT 0,
+    last_accessed_at DATETIME,
+    created_by TEXT,
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+CREATE INDEX IF NOT EXISTS idx_share_links_tenant ON share_links(tenant_id, created_at);
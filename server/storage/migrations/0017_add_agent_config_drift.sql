@@ -0,0 +1,32 @@
+-- Agent config drift
+-- The latest settings each agent reports in heartbeats, compared against the
+-- settings policy resolved for it, plus a history of drift state changes and
+-- re-enforcement requests.
+
+CREATE TABLE IF NOT EXISTS agent_config_drift (
+    agent_id TEXT PRIMARY KEY,
+    tenant_id TEXT,
+    expected_version TEXT,
+    reported_version TEXT,
+    drifted INTEGER NOT NULL DEFAULT 0,
+    fields_json TEXT,
+    checked_at DATETIME NOT NULL,
+    drift_since DATETIME,
+    enforce_requested_at DATETIME,
+    enforce_requested_by TEXT,
+    last_enforced_at DATETIME
+);
+CREATE INDEX IF NOT EXISTS idx_agent_config_drift_tenant ON agent_config_drift(tenant_id, drifted);
+
+CREATE TABLE IF NOT EXISTS agent_config_drift_events (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    agent_id TEXT NOT NULL,
+    tenant_id TEXT,
+    event TEXT NOT NULL,
+    fields_json TEXT,
+    expected_version TEXT,
+    reported_version TEXT,
+    actor TEXT,
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+CREATE INDEX IF NOT EXISTS idx_agent_config_drift_events_agent ON agent_config_drift_events(agent_id, created_at);
@@ -0,0 +1,22 @@
+-- Device notes and maintenance log
+-- Free-form notes and structured maintenance entries (part replaced, service
+-- visit, firmware change) written by users against a device serial.
+
+CREATE TABLE IF NOT EXISTS device_notes (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    serial TEXT NOT NULL,
+    tenant_id TEXT,
+    kind TEXT NOT NULL,
+    body TEXT,
+    part TEXT,
+    vendor TEXT,
+    firmware_from TEXT,
+    firmware_to TEXT,
+    page_count INTEGER,
+    author TEXT NOT NULL,
+    occurred_at DATETIME NOT NULL,
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+CREATE INDEX IF NOT EXISTS idx_device_notes_serial ON device_notes(serial, occurred_at);
+CREATE INDEX IF NOT EXISTS idx_device_notes_tenant ON device_notes(tenant_id, occurred_at);
@@ -0,0 +1,43 @@
+-- Incidents
+-- Printer problems tracked through open/investigating/waiting_parts/resolved,
+-- with linked alerts and a note history. Raised by hand or promoted from
+-- persistent device_offline and repeated paper_jam alerts.
+
+CREATE TABLE IF NOT EXISTS incidents (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    tenant_id TEXT,
+    title TEXT NOT NULL,
+    description TEXT,
+    severity TEXT NOT NULL,
+    status TEXT NOT NULL,
+    assignee TEXT,
+    device_serial TEXT,
+    agent_id TEXT,
+    source TEXT NOT NULL,
+    correlation_key TEXT,
+    created_by TEXT,
+    opened_at DATETIME NOT NULL,
+    updated_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    resolved_at DATETIME
+);
+CREATE INDEX IF NOT EXISTS idx_incidents_status ON incidents(status, opened_at);
+CREATE INDEX IF NOT EXISTS idx_incidents_tenant ON incidents(tenant_id, status);
+CREATE INDEX IF NOT EXISTS idx_incidents_key ON incidents(correlation_key);
+
+CREATE TABLE IF NOT EXISTS incident_alerts (
+    incident_id INTEGER NOT NULL,
+    alert_id INTEGER NOT NULL,
+    linked_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (incident_id, alert_id)
+);
+CREATE INDEX IF NOT EXISTS idx_incident_alerts_alert ON incident_alerts(alert_id);
+
+CREATE TABLE IF NOT EXISTS incident_notes (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    incident_id INTEGER NOT NULL,
+    kind TEXT NOT NULL,
+    body TEXT NOT NULL,
+    author TEXT NOT NULL,
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+CREATE INDEX IF NOT EXISTS idx_incident_notes_incident ON incident_notes(incident_id, created_at);
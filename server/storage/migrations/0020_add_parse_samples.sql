@@ -0,0 +1,23 @@
+-- Parse samples
+-- Parse-debug snapshots (raw SNMP responses and detection steps) uploaded by
+-- agents for devices with parsing gaps. One sample per agent and device IP.
+
+CREATE TABLE IF NOT EXISTS parse_samples (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    agent_id TEXT NOT NULL,
+    tenant_id TEXT,
+    ip TEXT NOT NULL,
+    serial TEXT,
+    manufacturer TEXT,
+    model TEXT,
+    sys_object_id TEXT,
+    missing_json TEXT,
+    steps_json TEXT,
+    raw_pdus_json TEXT,
+    pdu_count INTEGER NOT NULL DEFAULT 0,
+    captured_at DATETIME NOT NULL,
+    received_at DATETIME NOT NULL,
+    UNIQUE(agent_id, ip)
+);
+CREATE INDEX IF NOT EXISTS idx_parse_samples_tenant ON parse_samples(tenant_id, captured_at);
+CREATE INDEX IF NOT EXISTS idx_parse_samples_group ON parse_samples(manufacturer, sys_object_id);
@@ -0,0 +1,19 @@
+-- Unknown device telemetry
+-- Anonymized sysObjectID/sysDescr samples of printers agents could not
+-- attribute to a manufacturer (opt-in). One row per agent and sample.
+
+CREATE TABLE IF NOT EXISTS unknown_device_reports (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    agent_id TEXT NOT NULL,
+    tenant_id TEXT,
+    sys_object_id TEXT NOT NULL DEFAULT '',
+    sys_descr TEXT NOT NULL DEFAULT '',
+    enterprise TEXT,
+    model TEXT,
+    sightings INTEGER NOT NULL DEFAULT 0,
+    first_seen DATETIME NOT NULL,
+    last_seen DATETIME NOT NULL,
+    received_at DATETIME NOT NULL,
+    UNIQUE(agent_id, sys_object_id, sys_descr)
+);
+CREATE INDEX IF NOT EXISTS idx_unknown_device_reports_tenant ON unknown_device_reports(tenant_id);
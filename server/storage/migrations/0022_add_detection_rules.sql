@@ -0,0 +1,18 @@
+-- Vendor detection rules
+-- Server-managed sysObjectID prefix / sysDescr pattern rules that identify a
+-- device's manufacturer. Enabled rules are sent to every agent.
+
+CREATE TABLE IF NOT EXISTS detection_rules (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    manufacturer TEXT NOT NULL,
+    sys_object_id_prefix TEXT NOT NULL DEFAULT '',
+    sys_descr_regex TEXT NOT NULL DEFAULT '',
+    model_regex TEXT NOT NULL DEFAULT '',
+    vendor_module TEXT NOT NULL DEFAULT '',
+    priority INTEGER NOT NULL DEFAULT 0,
+    enabled INTEGER NOT NULL DEFAULT 1,
+    description TEXT,
+    created_by TEXT,
+    created_at DATETIME NOT NULL,
+    updated_at DATETIME NOT NULL
+);
@@ -0,0 +1,27 @@
+-- Action approvals (four-eyes)
+-- Dangerous remote operations held until a second administrator approves
+-- them. Pending requests expire after security.approval_expiry_hours.
+
+CREATE TABLE IF NOT EXISTS action_approvals (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    action TEXT NOT NULL,
+    tenant_id TEXT,
+    target_type TEXT NOT NULL,
+    target_id TEXT NOT NULL,
+    target_name TEXT,
+    summary TEXT NOT NULL,
+    reason TEXT,
+    payload_json TEXT,
+    status TEXT NOT NULL,
+    requested_by_id INTEGER NOT NULL,
+    requested_by TEXT NOT NULL,
+    decided_by TEXT,
+    decision_note TEXT,
+    result TEXT,
+    created_at DATETIME NOT NULL,
+    expires_at DATETIME NOT NULL,
+    decided_at DATETIME,
+    executed_at DATETIME
+);
+CREATE INDEX IF NOT EXISTS idx_action_approvals_status ON action_approvals(status, created_at);
+CREATE INDEX IF NOT EXISTS idx_action_approvals_target ON action_approvals(target_id, action);
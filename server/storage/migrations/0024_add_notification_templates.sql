@@ -0,0 +1,16 @@
+-- Notification templates
+-- Admin-edited email and webhook templates for alert notifications. A row
+-- with an empty tenant_id is the global default; tenant rows override it.
+
+CREATE TABLE IF NOT EXISTS notification_templates (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    tenant_id TEXT NOT NULL DEFAULT '',
+    channel_type TEXT NOT NULL,
+    subject TEXT,
+    text_body TEXT NOT NULL,
+    html_body TEXT,
+    updated_by TEXT,
+    created_at DATETIME NOT NULL,
+    updated_at DATETIME NOT NULL,
+    UNIQUE(tenant_id, channel_type)
+);
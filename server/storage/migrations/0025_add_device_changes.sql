@@ -0,0 +1,15 @@
+-- Device change feed
+-- One row per device or metrics change (and per device deletion), so external
+-- systems can sync incrementally from a cursor instead of pulling everything.
+
+CREATE TABLE IF NOT EXISTS device_changes (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    serial TEXT NOT NULL,
+    agent_id TEXT,
+    kind TEXT NOT NULL,
+    op TEXT NOT NULL,
+    content_hash TEXT,
+    changed_at DATETIME NOT NULL
+);
+CREATE INDEX IF NOT EXISTS idx_device_changes_agent ON device_changes(agent_id, id);
+CREATE INDEX IF NOT EXISTS idx_device_changes_serial ON device_changes(serial, kind, id);
@@ -0,0 +1,21 @@
+-- Scan-to-email path verification
+-- Test scans that MFPs email to the server's SMTP sink, tied to the device
+-- that sent them, so onboarding can confirm scan-to-email works.
+
+CREATE TABLE IF NOT EXISTS scan_path_checks (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    serial TEXT,
+    agent_id TEXT,
+    match_method TEXT,
+    verified INTEGER NOT NULL DEFAULT 0,
+    sender TEXT NOT NULL DEFAULT '',
+    recipients TEXT NOT NULL DEFAULT '',
+    subject TEXT NOT NULL DEFAULT '',
+    source_ip TEXT NOT NULL DEFAULT '',
+    attachment_count INTEGER NOT NULL DEFAULT 0,
+    attachment_bytes BIGINT NOT NULL DEFAULT 0,
+    attachments_json TEXT NOT NULL DEFAULT '[]',
+    received_at DATETIME NOT NULL
+);
+CREATE INDEX IF NOT EXISTS idx_scan_path_checks_serial ON scan_path_checks(serial, received_at);
+CREATE INDEX IF NOT EXISTS idx_scan_path_checks_received ON scan_path_checks(received_at);
@@ -0,0 +1,19 @@
+-- Metrics cold storage
+-- Periods of metrics_history moved to compressed Parquet files. The table
+-- keeps one summary row per device and day for archived periods.
+
+CREATE TABLE IF NOT EXISTS metrics_archives (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    period_start DATETIME NOT NULL,
+    period_end DATETIME NOT NULL,
+    path TEXT NOT NULL,
+    row_count BIGINT NOT NULL DEFAULT 0,
+    summary_rows BIGINT NOT NULL DEFAULT 0,
+    device_count INTEGER NOT NULL DEFAULT 0,
+    size_bytes BIGINT NOT NULL DEFAULT 0,
+    sha256 TEXT NOT NULL DEFAULT '',
+    created_at DATETIME NOT NULL,
+    restored_at DATETIME,
+    restored_until DATETIME
+);
+CREATE INDEX IF NOT EXISTS idx_metrics_archives_period ON metrics_archives(period_start);
@@ -0,0 +1,18 @@
+-- Per-user alert notification preferences
+-- Which severities and alert types each user receives, by email and/or
+-- webhook, immediately or as an hourly/daily digest.
+
+CREATE TABLE IF NOT EXISTS user_notification_prefs (
+    user_id INTEGER PRIMARY KEY,
+    enabled INTEGER NOT NULL DEFAULT 0,
+    min_severity TEXT NOT NULL DEFAULT 'warning',
+    alert_types TEXT,
+    email_enabled INTEGER NOT NULL DEFAULT 1,
+    email_address TEXT,
+    webhook_url TEXT,
+    frequency TEXT NOT NULL DEFAULT 'immediate',
+    digest_hour INTEGER NOT NULL DEFAULT 8,
+    last_alert_id BIGINT NOT NULL DEFAULT 0,
+    last_digest_at DATETIME,
+    updated_at DATETIME NOT NULL
+);
@@ -0,0 +1,6 @@
+-- Cartridge prices in the baseline library
+-- cartridge_cost is the price of one cartridge in the fleet's currency; with
+-- rated_yield it gives the supply cost per page used by device comparisons.
+-- NULL when no price is known.
+
+ALTER TABLE yield_baselines ADD COLUMN cartridge_cost REAL;
@@ -0,0 +1,28 @@
+-- Regional relay servers
+-- Relays accept agent uploads close to the agents and forward them to the
+-- central server in compressed batches. Only a hash of each relay's token is
+-- stored. relay_agents records which relay an agent currently uses; agents
+-- uploading directly have no row.
+
+CREATE TABLE IF NOT EXISTS relays (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    name TEXT NOT NULL UNIQUE,
+    region TEXT NOT NULL DEFAULT '',
+    url TEXT NOT NULL,
+    token_hash TEXT NOT NULL UNIQUE,
+    version TEXT,
+    last_seen DATETIME,
+    queue_depth BIGINT NOT NULL DEFAULT 0,
+    forwarded_total BIGINT NOT NULL DEFAULT 0,
+    dropped_total BIGINT NOT NULL DEFAULT 0,
+    created_by TEXT,
+    created_at DATETIME NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS relay_agents (
+    agent_id TEXT PRIMARY KEY,
+    relay_id BIGINT NOT NULL,
+    rtt_ms INTEGER NOT NULL DEFAULT 0,
+    assigned_at DATETIME NOT NULL
+);
+CREATE INDEX IF NOT EXISTS idx_relay_agents_relay ON relay_agents(relay_id);
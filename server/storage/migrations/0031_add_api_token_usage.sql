@@ -0,0 +1,20 @@
+-- Customer-facing API keys
+-- API tokens are sessions with kind 'api' and an optional name. They are
+-- exempt from the idle timeout; expiry_warned_at records the one warning sent
+-- before a token expires. api_token_usage keeps daily request and error
+-- counts per token (stored hash) and normalized endpoint.
+
+ALTER TABLE sessions ADD COLUMN kind TEXT NOT NULL DEFAULT 'web';
+ALTER TABLE sessions ADD COLUMN name TEXT;
+ALTER TABLE sessions ADD COLUMN expiry_warned_at DATETIME;
+
+CREATE TABLE IF NOT EXISTS api_token_usage (
+    token TEXT NOT NULL,
+    day TEXT NOT NULL,
+    endpoint TEXT NOT NULL,
+    requests BIGINT NOT NULL DEFAULT 0,
+    errors BIGINT NOT NULL DEFAULT 0,
+    last_used_at DATETIME NOT NULL,
+    PRIMARY KEY (token, day, endpoint)
+);
+CREATE INDEX IF NOT EXISTS idx_api_token_usage_day ON api_token_usage(day);
@@ -0,0 +1,5 @@
+-- Agent runtime environment
+-- Agents report where they run (container, systemd, windows_service, service
+-- or interactive) with each heartbeat. Older agents leave it empty.
+
+ALTER TABLE agents ADD COLUMN runtime_environment TEXT;
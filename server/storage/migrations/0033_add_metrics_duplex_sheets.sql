@@ -0,0 +1,6 @@
+-- Duplex sheet counter on metrics
+-- Agents upload the device's duplex sheet counter with each metrics snapshot;
+-- the sustainability report uses it for sheet counts and duplex adoption.
+-- Devices without the counter (and older agents) store 0.
+
+ALTER TABLE metrics_history ADD COLUMN duplex_sheets INTEGER DEFAULT 0;
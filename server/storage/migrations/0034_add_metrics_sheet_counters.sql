@@ -0,0 +1,7 @@
+-- Simplex and N-up sheet counters on metrics
+-- Agents normalize vendor duplex/simplex/N-up counters to sheets of paper and
+-- upload them with each metrics snapshot. Devices that don't expose a counter
+-- (and older agents) store 0.
+
+ALTER TABLE metrics_history ADD COLUMN simplex_sheets INTEGER DEFAULT 0;
+ALTER TABLE metrics_history ADD COLUMN nup_sheets INTEGER DEFAULT 0;
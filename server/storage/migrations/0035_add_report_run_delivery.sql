@@ -0,0 +1,9 @@
+-- Email delivery status on report runs
+-- Scheduled reports with email recipients are mailed when they complete.
+-- delivered_to holds the comma-separated recipients that accepted the mail,
+-- delivery_error the reason delivery failed. Both stay empty for runs that
+-- were not emailed.
+
+ALTER TABLE report_runs ADD COLUMN delivered_to TEXT;
+ALTER TABLE report_runs ADD COLUMN delivered_at DATETIME;
+ALTER TABLE report_runs ADD COLUMN delivery_error TEXT;
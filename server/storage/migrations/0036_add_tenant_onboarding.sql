@@ -0,0 +1,24 @@
+-- Tenant onboarding checklist and default dashboards
+-- kind classifies a tenant as an MSP customer or an internal IT department
+-- and picks the dashboard layout a new tenant starts with. Checklist progress
+-- is derived from live data; tenant_onboarding only stores steps an operator
+-- marked done or skipped by hand. tenant_dashboards holds layouts that were
+-- customized; tenants without a row use the default for their kind.
+
+ALTER TABLE tenants ADD COLUMN kind TEXT;
+
+CREATE TABLE IF NOT EXISTS tenant_onboarding (
+    tenant_id TEXT NOT NULL,
+    step TEXT NOT NULL,
+    status TEXT NOT NULL,
+    updated_at DATETIME NOT NULL,
+    updated_by TEXT,
+    PRIMARY KEY (tenant_id, step)
+);
+
+CREATE TABLE IF NOT EXISTS tenant_dashboards (
+    tenant_id TEXT PRIMARY KEY,
+    layout TEXT NOT NULL,
+    updated_at DATETIME NOT NULL,
+    updated_by TEXT
+);
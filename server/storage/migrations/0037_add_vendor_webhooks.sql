@@ -0,0 +1,58 @@
+-- Vendor cloud webhook inbox
+-- vendor_webhook_secrets holds each tenant's signing secret per vendor cloud
+-- platform (HP Smart Device Services, Lexmark Cloud); deliveries are posted to
+-- /api/v1/integrations/webhooks/{source}/{tenant_id}. vendor_webhook_deliveries
+-- records each signed webhook received; retried deliveries are recognized by
+-- (tenant_id, source, delivery_id). vendor_readings holds the alerts, supply
+-- levels and counters a delivery carried, matched by serial to the tenant's
+-- devices, with the source of each data point and how it compares with the
+-- agent's reading (reconcile, agent_value, agent_observed_at).
+
+CREATE TABLE IF NOT EXISTS vendor_webhook_secrets (
+    tenant_id TEXT NOT NULL,
+    source TEXT NOT NULL,
+    secret TEXT NOT NULL,
+    created_by TEXT,
+    created_at DATETIME NOT NULL,
+    PRIMARY KEY (tenant_id, source)
+);
+
+CREATE TABLE IF NOT EXISTS vendor_webhook_deliveries (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    tenant_id TEXT NOT NULL DEFAULT '',
+    source TEXT NOT NULL,
+    delivery_id TEXT NOT NULL,
+    status TEXT NOT NULL,
+    error TEXT,
+    events INTEGER NOT NULL DEFAULT 0,
+    matched INTEGER NOT NULL DEFAULT 0,
+    unmatched INTEGER NOT NULL DEFAULT 0,
+    source_ip TEXT,
+    received_at DATETIME NOT NULL
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_vendor_webhook_deliveries_tenant ON vendor_webhook_deliveries(tenant_id, source, delivery_id);
+CREATE INDEX IF NOT EXISTS idx_vendor_webhook_deliveries_received ON vendor_webhook_deliveries(received_at);
+
+CREATE TABLE IF NOT EXISTS vendor_readings (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    delivery_id INTEGER NOT NULL,
+    source TEXT NOT NULL,
+    serial TEXT NOT NULL,
+    agent_id TEXT,
+    tenant_id TEXT,
+    kind TEXT NOT NULL,
+    metric TEXT NOT NULL DEFAULT '',
+    value BIGINT NOT NULL DEFAULT 0,
+    severity TEXT,
+    state TEXT,
+    message TEXT,
+    observed_at DATETIME NOT NULL,
+    received_at DATETIME NOT NULL,
+    reconcile TEXT,
+    agent_value BIGINT,
+    agent_observed_at DATETIME,
+    alert_id INTEGER
+);
+CREATE INDEX IF NOT EXISTS idx_vendor_readings_serial ON vendor_readings(serial, observed_at);
+CREATE INDEX IF NOT EXISTS idx_vendor_readings_delivery ON vendor_readings(delivery_id);
+CREATE INDEX IF NOT EXISTS idx_vendor_readings_received ON vendor_readings(received_at);
@@ -0,0 +1,81 @@
+package storage
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestUserNotificationPrefs(t *testing.T) {
+	t.Parallel()
+	s, err := NewSQLiteStore(":memory:")
+	if err != nil {
+		t.Fatalf("NewSQLiteStore: %v", err)
+	}
+	defer s.Close()
+	ctx := context.Background()
+
+	user := &User{Username: "tech", Role: RoleOperator, TenantID: "t1", Email: "tech@example.com"}
+	if err := s.CreateUser(ctx, user, "Secret-pass-1"); err != nil {
+		t.Fatalf("CreateUser: %v", err)
+	}
+	var alertIDs []int64
+	for i := 0; i < 3; i++ {
+		id, err := s.CreateAlert(ctx, &Alert{Type: AlertTypeTonerLow, Severity: AlertSeverityWarning, Scope: AlertScopeDevice,
+			Status: AlertStatusActive, TenantID: "t1", Title: "Toner low", TriggeredAt: time.Now()})
+		if err != nil {
+			t.Fatalf("CreateAlert: %v", err)
+		}
+		alertIDs = append(alertIDs, id)
+	}
+
+	if got, err := s.GetUserNotificationPrefs(ctx, user.ID); err != nil || got != nil {
+		t.Fatalf("expected no prefs, got %+v, %v", got, err)
+	}
+	if err := s.UpsertUserNotificationPrefs(ctx, &UserNotificationPrefs{UserID: user.ID, Frequency: "weekly"}); err == nil {
+		t.Fatal("expected unknown frequency to be rejected")
+	}
+
+	prefs := &UserNotificationPrefs{UserID: user.ID, Enabled: true, MinSeverity: "Critical", AlertTypes: []string{"toner_low", " Toner_Low ", "paper_jam"},
+		EmailEnabled: true, Frequency: NotifyDaily, DigestHour: 7}
+	if err := s.UpsertUserNotificationPrefs(ctx, prefs); err != nil {
+		t.Fatalf("UpsertUserNotificationPrefs: %v", err)
+	}
+	got, err := s.GetUserNotificationPrefs(ctx, user.ID)
+	if err != nil || got == nil {
+		t.Fatalf("GetUserNotificationPrefs: %+v, %v", got, err)
+	}
+	// Enabling starts after the existing alerts
+	if got.MinSeverity != "critical" || len(got.AlertTypes) != 2 || got.DigestHour != 7 || got.LastAlertID != alertIDs[2] {
+		t.Errorf("stored prefs = %+v", got)
+	}
+
+	after, err := s.ListAlertsAfter(ctx, alertIDs[0], 10)
+	if err != nil || len(after) != 2 || after[0].ID != alertIDs[1] {
+		t.Fatalf("ListAlertsAfter = %v, %v", after, err)
+	}
+
+	digest := time.Now().UTC().Truncate(time.Second)
+	if err := s.SetUserNotificationProgress(ctx, user.ID, 99, &digest); err != nil {
+		t.Fatalf("SetUserNotificationProgress: %v", err)
+	}
+	// Saving again keeps the delivery progress
+	prefs.Frequency = NotifyHourly
+	if err := s.UpsertUserNotificationPrefs(ctx, prefs); err != nil {
+		t.Fatalf("UpsertUserNotificationPrefs: %v", err)
+	}
+	enabled, err := s.ListEnabledUserNotificationPrefs(ctx)
+	if err != nil || len(enabled) != 1 {
+		t.Fatalf("ListEnabledUserNotificationPrefs = %v, %v", enabled, err)
+	}
+	if e := enabled[0]; e.Frequency != NotifyHourly || e.LastAlertID != 99 || e.LastDigestAt == nil || !e.LastDigestAt.Equal(digest) {
+		t.Errorf("after resave = %+v", e)
+	}
+
+	if err := s.DeleteUser(ctx, user.ID); err != nil {
+		t.Fatalf("DeleteUser: %v", err)
+	}
+	if got, _ := s.GetUserNotificationPrefs(ctx, user.ID); got != nil {
+		t.Errorf("prefs survived user deletion")
+	}
+}
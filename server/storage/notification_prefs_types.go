@@ -0,0 +1,129 @@
+package storage
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Delivery frequencies for user notifications.
+const (
+	NotifyImmediate = "immediate"
+	NotifyHourly    = "hourly"
+	NotifyDaily     = "daily"
+)
+
+// UserNotificationPrefs controls which alerts a single user is told about,
+// how, and how often. Users without preferences receive nothing directly;
+// rule channels still deliver as before.
+type UserNotificationPrefs struct {
+	UserID      int64  `json:"user_id"`
+	Enabled     bool   `json:"enabled"`
+	MinSeverity string `json:"min_severity"`
+	// AlertTypes limits notifications to these alert types; empty means all
+	AlertTypes   []string `json:"alert_types,omitempty"`
+	EmailEnabled bool     `json:"email_enabled"`
+	// EmailAddress overrides the address on the user's account
+	EmailAddress string `json:"email_address,omitempty"`
+	WebhookURL   string `json:"webhook_url,omitempty"`
+	Frequency    string `json:"frequency"`
+	// DigestHour is the UTC hour daily digests are sent at
+	DigestHour int `json:"digest_hour"`
+	// LastAlertID is the newest alert already delivered or queued for the digest
+	LastAlertID  int64      `json:"-"`
+	LastDigestAt *time.Time `json:"last_digest_at,omitempty"`
+	UpdatedAt    time.Time  `json:"updated_at"`
+}
+
+// DefaultUserNotificationPrefs returns the preferences shown to a user who
+// hasn't saved any: disabled, warnings and above, emailed immediately.
+func DefaultUserNotificationPrefs(userID int64) *UserNotificationPrefs {
+	return &UserNotificationPrefs{
+		UserID:       userID,
+		MinSeverity:  AlertSeverityWarning,
+		EmailEnabled: true,
+		Frequency:    NotifyImmediate,
+		DigestHour:   8,
+	}
+}
+
+// Validate normalizes the preferences before they are stored.
+func (p *UserNotificationPrefs) Validate() error {
+	p.MinSeverity = strings.ToLower(strings.TrimSpace(p.MinSeverity))
+	switch p.MinSeverity {
+	case AlertSeverityInfo, AlertSeverityWarning, AlertSeverityCritical:
+	case "":
+		p.MinSeverity = AlertSeverityWarning
+	default:
+		return fmt.Errorf("unknown severity %q (want info, warning or critical)", p.MinSeverity)
+	}
+
+	p.Frequency = strings.ToLower(strings.TrimSpace(p.Frequency))
+	switch p.Frequency {
+	case NotifyImmediate, NotifyHourly, NotifyDaily:
+	case "":
+		p.Frequency = NotifyImmediate
+	default:
+		return fmt.Errorf("unknown frequency %q (want immediate, hourly or daily)", p.Frequency)
+	}
+	if p.DigestHour < 0 || p.DigestHour > 23 {
+		return fmt.Errorf("digest_hour must be between 0 and 23")
+	}
+
+	seen := make(map[string]bool, len(p.AlertTypes))
+	types := make([]string, 0, len(p.AlertTypes))
+	for _, t := range p.AlertTypes {
+		t = strings.ToLower(strings.TrimSpace(t))
+		if t != "" && !seen[t] {
+			seen[t] = true
+			types = append(types, t)
+		}
+	}
+	p.AlertTypes = types
+
+	p.EmailAddress = strings.TrimSpace(p.EmailAddress)
+	if p.EmailAddress != "" && (!strings.Contains(p.EmailAddress, "@") || strings.ContainsAny(p.EmailAddress, "\r\n")) {
+		return fmt.Errorf("invalid email address")
+	}
+	p.WebhookURL = strings.TrimSpace(p.WebhookURL)
+	if p.WebhookURL != "" {
+		u, err := url.Parse(p.WebhookURL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("webhook_url must be an http or https URL")
+		}
+	}
+	if p.Enabled && !p.EmailEnabled && p.WebhookURL == "" {
+		return fmt.Errorf("enable email or set a webhook URL")
+	}
+	return nil
+}
+
+// Wants reports whether an alert matches the severity and type filters.
+func (p *UserNotificationPrefs) Wants(alert *Alert) bool {
+	if alertSeverityRank(alert.Severity) < alertSeverityRank(p.MinSeverity) {
+		return false
+	}
+	if len(p.AlertTypes) == 0 {
+		return true
+	}
+	for _, t := range p.AlertTypes {
+		if strings.EqualFold(t, alert.Type) {
+			return true
+		}
+	}
+	return false
+}
+
+func alertSeverityRank(severity string) int {
+	switch strings.ToLower(severity) {
+	case AlertSeverityCritical:
+		return 3
+	case AlertSeverityWarning:
+		return 2
+	case AlertSeverityInfo:
+		return 1
+	default:
+		return 0
+	}
+}
@@ -0,0 +1,53 @@
+package storage
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// NotificationTemplate customizes how alert notifications of one channel type
+// are rendered. Templates use Go template syntax. A template with an empty
+// TenantID is the global default; a tenant template overrides it for alerts
+// belonging to that tenant.
+//
+// For email channels Subject and TextBody render the message and HTMLBody,
+// when set, adds an HTML alternative. For webhook channels TextBody renders
+// the JSON request body and Subject/HTMLBody are unused.
+type NotificationTemplate struct {
+	ID          int64       `json:"id"`
+	TenantID    string      `json:"tenant_id,omitempty"`
+	ChannelType ChannelType `json:"channel_type"`
+	Subject     string      `json:"subject,omitempty"`
+	TextBody    string      `json:"text_body"`
+	HTMLBody    string      `json:"html_body,omitempty"`
+	UpdatedBy   string      `json:"updated_by,omitempty"`
+	CreatedAt   time.Time   `json:"created_at"`
+	UpdatedAt   time.Time   `json:"updated_at"`
+}
+
+// NotificationTemplateChannelTypes lists the channel types whose messages can
+// be templated.
+var NotificationTemplateChannelTypes = []ChannelType{ChannelTypeEmail, ChannelTypeWebhook}
+
+// Validate checks a template before it is stored. It does not parse the
+// template bodies; callers render a preview for that.
+func (t *NotificationTemplate) Validate() error {
+	t.TenantID = strings.TrimSpace(t.TenantID)
+	t.ChannelType = strings.ToLower(strings.TrimSpace(t.ChannelType))
+	switch t.ChannelType {
+	case ChannelTypeEmail:
+		if strings.TrimSpace(t.Subject) == "" {
+			return fmt.Errorf("subject is required for email templates")
+		}
+	case ChannelTypeWebhook:
+		t.Subject = ""
+		t.HTMLBody = ""
+	default:
+		return fmt.Errorf("unsupported channel type %q (want email or webhook)", t.ChannelType)
+	}
+	if strings.TrimSpace(t.TextBody) == "" {
+		return fmt.Errorf("text_body is required")
+	}
+	return nil
+}
@@ -0,0 +1,65 @@
+package storage
+
+import (
+	"context"
+	"testing"
+)
+
+func TestNotificationTemplates(t *testing.T) {
+	t.Parallel()
+	s, err := NewSQLiteStore(":memory:")
+	if err != nil {
+		t.Fatalf("NewSQLiteStore: %v", err)
+	}
+	defer s.Close()
+	ctx := context.Background()
+
+	if err := s.UpsertNotificationTemplate(ctx, &NotificationTemplate{ChannelType: "sms", TextBody: "x"}); err == nil {
+		t.Fatal("expected unsupported channel type to be rejected")
+	}
+	if err := s.UpsertNotificationTemplate(ctx, &NotificationTemplate{ChannelType: ChannelTypeEmail, TextBody: "x"}); err == nil {
+		t.Fatal("expected email template without subject to be rejected")
+	}
+
+	global := &NotificationTemplate{ChannelType: ChannelTypeEmail, Subject: "{{.Alert.Title}}", TextBody: "global"}
+	if err := s.UpsertNotificationTemplate(ctx, global); err != nil {
+		t.Fatalf("UpsertNotificationTemplate: %v", err)
+	}
+	if got, err := s.ResolveNotificationTemplate(ctx, "t1", ChannelTypeEmail); err != nil || got == nil || got.ID != global.ID {
+		t.Fatalf("expected global fallback, got %+v, %v", got, err)
+	}
+
+	override := &NotificationTemplate{TenantID: "t1", ChannelType: ChannelTypeEmail, Subject: "Acme", TextBody: "tenant", HTMLBody: "<p>tenant</p>"}
+	if err := s.UpsertNotificationTemplate(ctx, override); err != nil {
+		t.Fatalf("UpsertNotificationTemplate: %v", err)
+	}
+	if got, _ := s.ResolveNotificationTemplate(ctx, "t1", ChannelTypeEmail); got == nil || got.TextBody != "tenant" || got.HTMLBody != "<p>tenant</p>" {
+		t.Fatalf("expected tenant override, got %+v", got)
+	}
+	if got, _ := s.ResolveNotificationTemplate(ctx, "t2", ChannelTypeEmail); got == nil || got.TextBody != "global" {
+		t.Fatalf("expected global for other tenant, got %+v", got)
+	}
+	if got, _ := s.ResolveNotificationTemplate(ctx, "t1", ChannelTypeWebhook); got != nil {
+		t.Fatalf("expected no webhook template, got %+v", got)
+	}
+
+	// Saving again for the same tenant and channel replaces the template
+	update := &NotificationTemplate{TenantID: "t1", ChannelType: ChannelTypeEmail, Subject: "Acme", TextBody: "updated"}
+	if err := s.UpsertNotificationTemplate(ctx, update); err != nil {
+		t.Fatalf("UpsertNotificationTemplate: %v", err)
+	}
+	if update.ID != override.ID {
+		t.Errorf("upsert created a new row: %d != %d", update.ID, override.ID)
+	}
+	list, err := s.ListNotificationTemplates(ctx)
+	if err != nil || len(list) != 2 || list[0].TenantID != "" || list[1].TextBody != "updated" {
+		t.Fatalf("ListNotificationTemplates: %+v, %v", list, err)
+	}
+
+	if err := s.DeleteNotificationTemplate(ctx, override.ID); err != nil {
+		t.Fatalf("DeleteNotificationTemplate: %v", err)
+	}
+	if got, _ := s.GetNotificationTemplate(ctx, override.ID); got != nil {
+		t.Errorf("template still present after delete: %+v", got)
+	}
+}
@@ -0,0 +1,42 @@
+package storage
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// MaxParseSamplePDUs caps the raw PDUs kept per sample.
+const MaxParseSamplePDUs = 2000
+
+// ParseSample is a parse-debug snapshot an agent uploaded for a device its
+// parser could not fully read: the raw SNMP responses and the parser's
+// detection steps. One sample is kept per agent and device IP; a newer
+// upload replaces it.
+type ParseSample struct {
+	ID           int64           `json:"id"`
+	AgentID      string          `json:"agent_id"`
+	TenantID     string          `json:"tenant_id,omitempty"`
+	IP           string          `json:"ip"`
+	Serial       string          `json:"serial,omitempty"`
+	Manufacturer string          `json:"manufacturer,omitempty"`
+	Model        string          `json:"model,omitempty"`
+	SysObjectID  string          `json:"sys_object_id,omitempty"`
+	Missing      []string        `json:"missing,omitempty"` // serial, model, toner, counters
+	Steps        []string        `json:"steps,omitempty"`
+	RawPDUs      json.RawMessage `json:"raw_pdus,omitempty"`
+	PDUCount     int             `json:"pdu_count"`
+	CapturedAt   time.Time       `json:"captured_at"`
+	ReceivedAt   time.Time       `json:"received_at"`
+}
+
+// ParseSampleFilter narrows ListParseSamples. Zero values match everything.
+type ParseSampleFilter struct {
+	TenantIDs    []string // Empty = all tenants
+	AgentID      string
+	Manufacturer string // Case-insensitive exact match; "" matches all
+	SysObjectID  string
+	Missing      string // Only samples missing this field
+	Limit        int
+	// WithPDUs includes RawPDUs; lists leave them out by default
+	WithPDUs bool
+}
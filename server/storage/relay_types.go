@@ -0,0 +1,49 @@
+package storage
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Relay is a regional server that accepts agent uploads and forwards them to
+// this server in compressed batches. Only a hash of its token is stored.
+type Relay struct {
+	ID             int64      `json:"id"`
+	Name           string     `json:"name"`
+	Region         string     `json:"region,omitempty"`
+	URL            string     `json:"url"` // Base URL agents use to reach the relay
+	TokenHash      string     `json:"-"`
+	Version        string     `json:"version,omitempty"`
+	LastSeen       *time.Time `json:"last_seen,omitempty"`
+	QueueDepth     int64      `json:"queue_depth"`     // Uploads waiting on the relay at the last heartbeat
+	ForwardedTotal int64      `json:"forwarded_total"` // Uploads forwarded since the relay started
+	DroppedTotal   int64      `json:"dropped_total"`   // Uploads dropped because the relay queue was full
+	AgentCount     int        `json:"agent_count"`     // Agents currently assigned (not stored)
+	CreatedBy      string     `json:"created_by,omitempty"`
+	CreatedAt      time.Time  `json:"created_at"`
+}
+
+// Validate checks a relay before it is stored.
+func (r *Relay) Validate() error {
+	r.Name = strings.TrimSpace(r.Name)
+	r.Region = strings.TrimSpace(r.Region)
+	r.URL = strings.TrimRight(strings.TrimSpace(r.URL), "/")
+	if r.Name == "" {
+		return fmt.Errorf("name is required")
+	}
+	u, err := url.Parse(r.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("url must be an absolute http(s) URL")
+	}
+	return nil
+}
+
+// RelayHeartbeat is the status a relay reports periodically.
+type RelayHeartbeat struct {
+	Version        string `json:"version,omitempty"`
+	QueueDepth     int64  `json:"queue_depth"`
+	ForwardedTotal int64  `json:"forwarded_total"`
+	DroppedTotal   int64  `json:"dropped_total"`
+}
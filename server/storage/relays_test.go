@@ -0,0 +1,85 @@
+package storage
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestRelays(t *testing.T) {
+	t.Parallel()
+	s, err := NewSQLiteStore(":memory:")
+	if err != nil {
+		t.Fatalf("NewSQLiteStore: %v", err)
+	}
+	defer s.Close()
+	ctx := context.Background()
+
+	if _, err := s.CreateRelay(ctx, &Relay{Name: "eu", URL: "relay-eu:8443"}); err == nil {
+		t.Fatal("expected relay without an absolute URL to be rejected")
+	}
+	eu := &Relay{Name: " eu-west ", Region: "Europe", URL: "https://relay-eu.example.com/", CreatedBy: "admin"}
+	token, err := s.CreateRelay(ctx, eu)
+	if err != nil {
+		t.Fatalf("CreateRelay: %v", err)
+	}
+	if token == "" || eu.TokenHash == token || eu.URL != "https://relay-eu.example.com" || eu.Name != "eu-west" {
+		t.Fatalf("unexpected relay after create: %+v token %q", eu, token)
+	}
+	ap := &Relay{Name: "ap-south", URL: "http://10.0.0.5:8443"}
+	if _, err := s.CreateRelay(ctx, ap); err != nil {
+		t.Fatalf("CreateRelay: %v", err)
+	}
+
+	got, err := s.GetRelayByToken(ctx, token)
+	if err != nil || got == nil || got.ID != eu.ID {
+		t.Fatalf("GetRelayByToken: %+v, %v", got, err)
+	}
+	if got, err := s.GetRelayByToken(ctx, "nope"); err != nil || got != nil {
+		t.Fatalf("GetRelayByToken(unknown) = %+v, %v", got, err)
+	}
+
+	seen := time.Now().UTC().Truncate(time.Second)
+	if err := s.UpdateRelayHeartbeat(ctx, eu.ID, RelayHeartbeat{Version: "1.2.3", QueueDepth: 4, ForwardedTotal: 90, DroppedTotal: 1}, seen); err != nil {
+		t.Fatalf("UpdateRelayHeartbeat: %v", err)
+	}
+	for _, agentID := range []string{"a1", "a2"} {
+		if err := s.SetAgentRelay(ctx, agentID, eu.ID, 20); err != nil {
+			t.Fatalf("SetAgentRelay: %v", err)
+		}
+	}
+	if err := s.SetAgentRelay(ctx, "a2", ap.ID, 35); err != nil {
+		t.Fatalf("SetAgentRelay (move): %v", err)
+	}
+	if id, err := s.GetAgentRelayID(ctx, "a2"); err != nil || id != ap.ID {
+		t.Fatalf("GetAgentRelayID = %d, %v", id, err)
+	}
+	if err := s.SetAgentRelay(ctx, "a2", 0, 0); err != nil {
+		t.Fatalf("SetAgentRelay (direct): %v", err)
+	}
+	if id, _ := s.GetAgentRelayID(ctx, "a2"); id != 0 {
+		t.Fatalf("expected a2 to upload directly, got relay %d", id)
+	}
+
+	relays, err := s.ListRelays(ctx)
+	if err != nil || len(relays) != 2 {
+		t.Fatalf("ListRelays: %d relays, %v", len(relays), err)
+	}
+	if r := relays[1]; r.Name != "eu-west" || r.AgentCount != 1 || r.Version != "1.2.3" || r.QueueDepth != 4 ||
+		r.LastSeen == nil || !r.LastSeen.Equal(seen) {
+		t.Fatalf("unexpected relay status: %+v", r)
+	}
+
+	if err := s.DeleteRelay(ctx, eu.ID); err != nil {
+		t.Fatalf("DeleteRelay: %v", err)
+	}
+	if got, _ := s.GetRelayByToken(ctx, token); got != nil {
+		t.Fatal("token of a deleted relay still resolves")
+	}
+	if id, _ := s.GetAgentRelayID(ctx, "a1"); id != 0 {
+		t.Fatalf("assignment to deleted relay kept: %d", id)
+	}
+	if err := s.DeleteRelay(ctx, eu.ID); err == nil {
+		t.Fatal("expected deleting a missing relay to fail")
+	}
+}
@@ -34,6 +34,13 @@ const (
 	ReportTypeTopPrinters      ReportType = "top_printers"
 	ReportTypeOfflineDevices   ReportType = "offline_devices"
 	ReportTypeErrorDevices     ReportType = "error_devices"
+	ReportTypeSecurityPosture  ReportType = "security_posture"
+	ReportTypeCapacityPlanning ReportType = "capacity_planning"
+	ReportTypeTonerYield       ReportType = "toner_yield"
+	ReportTypeMaintenanceLog   ReportType = "maintenance_log"
+	ReportTypeDataQuality      ReportType = "data_quality"
+	ReportTypeSustainability   ReportType = "sustainability"
+	ReportTypeAvailability     ReportType = "availability"
 	ReportTypeCustom           ReportType = "custom"
 )
 
@@ -52,6 +59,13 @@ func GetBuiltInReportTypes() []ReportType {
 		ReportTypeUsageByTenant,
 		ReportTypeUsageTrends,
 		ReportTypeTopPrinters,
+		ReportTypeSecurityPosture,
+		ReportTypeCapacityPlanning,
+		ReportTypeTonerYield,
+		ReportTypeMaintenanceLog,
+		ReportTypeDataQuality,
+		ReportTypeSustainability,
+		ReportTypeAvailability,
 	}
 }
 
@@ -63,6 +77,7 @@ const (
 	ReportFormatCSV  ReportFormat = "csv"
 	ReportFormatPDF  ReportFormat = "pdf"
 	ReportFormatHTML ReportFormat = "html"
+	ReportFormatXLSX ReportFormat = "xlsx"
 )
 
 // ReportScope represents the scope of a report (string type for backwards compatibility)
@@ -209,7 +224,11 @@ type ReportRun struct {
 	ResultData      string     `json:"result_data,omitempty"`
 	ErrorMessage    string     `json:"error_message,omitempty"`
 	RunBy           string     `json:"run_by,omitempty"`
-	CreatedAt       time.Time  `json:"created_at"`
+	// Email delivery; empty when the run was not emailed
+	DeliveredTo   []string   `json:"delivered_to,omitempty"`
+	DeliveredAt   *time.Time `json:"delivered_at,omitempty"`
+	DeliveryError string     `json:"delivery_error,omitempty"`
+	CreatedAt     time.Time  `json:"created_at"`
 }
 
 // ReportSummary provides a summary of reporting state
@@ -478,6 +478,8 @@ func TestReportRunLifecycle(t *testing.T) {
 	run.ResultSize = 1024
 	run.ResultPath = "/reports/output/test.csv"
 	run.ResultData = "col1,col2\nval1,val2"
+	run.DeliveredTo = []string{"ops@example.com", "billing@example.com"}
+	run.DeliveredAt = &completedAt
 
 	err = s.UpdateReportRun(ctx, run)
 	if err != nil {
@@ -497,6 +499,9 @@ func TestReportRunLifecycle(t *testing.T) {
 	if got.ResultPath != "/reports/output/test.csv" {
 		t.Errorf("result_path not updated: got=%q", got.ResultPath)
 	}
+	if len(got.DeliveredTo) != 2 || got.DeliveredTo[1] != "billing@example.com" || got.DeliveredAt == nil {
+		t.Errorf("delivery not stored: to=%v at=%v", got.DeliveredTo, got.DeliveredAt)
+	}
 
 	// List runs
 	runs, err := s.ListReportRuns(ctx, ReportRunFilter{ReportID: report.ID})
@@ -506,6 +511,9 @@ func TestReportRunLifecycle(t *testing.T) {
 	if len(runs) != 1 {
 		t.Fatalf("expected 1 run, got %d", len(runs))
 	}
+	if len(runs[0].DeliveredTo) != 2 {
+		t.Errorf("delivery missing from listed run: %v", runs[0].DeliveredTo)
+	}
 
 	// List with status filter
 	runs, err = s.ListReportRuns(ctx, ReportRunFilter{Status: ReportRunStatusCompleted})
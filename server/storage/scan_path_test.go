@@ -0,0 +1,64 @@
+package storage
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestScanPathChecks(t *testing.T) {
+	t.Parallel()
+	s, err := NewSQLiteStore(":memory:")
+	if err != nil {
+		t.Fatalf("NewSQLiteStore: %v", err)
+	}
+	defer s.Close()
+	ctx := context.Background()
+
+	if latest, err := s.GetLatestScanPathCheck(ctx, "SER1"); err != nil || latest != nil {
+		t.Fatalf("GetLatestScanPathCheck on empty store = %+v, %v", latest, err)
+	}
+
+	now := time.Now().UTC()
+	checks := []*ScanPathCheck{
+		{Serial: "SER1", AgentID: "agent-1", MatchMethod: ScanPathMatchSubjectSerial, Verified: true, Sender: "mfp@example.com",
+			Recipients: []string{"scans@pm.local"}, Subject: "Scan SER1", SourceIP: "10.0.0.5", AttachmentCount: 1, AttachmentBytes: 2048,
+			Attachments: []ScanPathAttachment{{Filename: "scan.pdf", ContentType: "application/pdf", Size: 2048}}, ReceivedAt: now.Add(-time.Hour)},
+		{Serial: "SER1", AgentID: "agent-1", MatchMethod: ScanPathMatchSourceIP, Sender: "mfp@example.com", SourceIP: "10.0.0.5", ReceivedAt: now},
+		{Sender: "someone@example.com", Subject: "hello", SourceIP: "10.0.0.9", ReceivedAt: now},
+	}
+	for _, c := range checks {
+		if err := s.SaveScanPathCheck(ctx, c); err != nil {
+			t.Fatalf("SaveScanPathCheck: %v", err)
+		}
+		if c.ID == 0 {
+			t.Fatal("expected ID to be set")
+		}
+	}
+
+	// The verified check wins over a later failed one
+	latest, err := s.GetLatestScanPathCheck(ctx, "SER1")
+	if err != nil || latest == nil || latest.ID != checks[0].ID {
+		t.Fatalf("GetLatestScanPathCheck = %+v, %v", latest, err)
+	}
+	if !latest.Verified || len(latest.Attachments) != 1 || latest.Attachments[0].Filename != "scan.pdf" || len(latest.Recipients) != 1 {
+		t.Errorf("round trip = %+v", latest)
+	}
+
+	all, err := s.ListScanPathChecks(ctx, ScanPathCheckFilter{})
+	if err != nil || len(all) != 3 {
+		t.Fatalf("ListScanPathChecks = %d, %v", len(all), err)
+	}
+	unmatched, _ := s.ListScanPathChecks(ctx, ScanPathCheckFilter{Unmatched: true})
+	if len(unmatched) != 1 || unmatched[0].Serial != "" {
+		t.Errorf("unmatched = %+v", unmatched)
+	}
+	scoped, _ := s.ListScanPathChecks(ctx, ScanPathCheckFilter{AgentIDs: []string{"agent-1"}, Limit: 1})
+	if len(scoped) != 1 || scoped[0].ID != checks[1].ID {
+		t.Errorf("scoped = %+v", scoped)
+	}
+
+	if n, err := s.DeleteScanPathChecksBefore(ctx, now.Add(-time.Minute)); err != nil || n != 1 {
+		t.Errorf("DeleteScanPathChecksBefore = %d, %v", n, err)
+	}
+}
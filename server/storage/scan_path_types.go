@@ -0,0 +1,46 @@
+package storage
+
+import "time"
+
+// Scan path check match methods: how a received test scan was tied to a
+// device.
+const (
+	ScanPathMatchSubjectSerial = "subject_serial" // Serial in the subject or attachment name
+	ScanPathMatchSender        = "sender"         // Sender local part is the serial or hostname
+	ScanPathMatchSourceIP      = "source_ip"      // Delivered from the device's IP
+)
+
+// ScanPathAttachment is a file carried by a received test scan.
+type ScanPathAttachment struct {
+	Filename    string `json:"filename"`
+	ContentType string `json:"content_type"`
+	Size        int64  `json:"size"`
+}
+
+// ScanPathCheck records a scan-to-email test message received from an MFP.
+// Verified means the message was tied to a device and carried a scan, so the
+// device's scan-to-email path works end to end. Unmatched messages are kept
+// with an empty Serial so admins can see what arrived.
+type ScanPathCheck struct {
+	ID              int64                `json:"id"`
+	Serial          string               `json:"serial,omitempty"`
+	AgentID         string               `json:"agent_id,omitempty"`
+	MatchMethod     string               `json:"match_method,omitempty"`
+	Verified        bool                 `json:"verified"`
+	Sender          string               `json:"sender"`
+	Recipients      []string             `json:"recipients"`
+	Subject         string               `json:"subject"`
+	SourceIP        string               `json:"source_ip"`
+	AttachmentCount int                  `json:"attachment_count"`
+	AttachmentBytes int64                `json:"attachment_bytes"`
+	Attachments     []ScanPathAttachment `json:"attachments,omitempty"`
+	ReceivedAt      time.Time            `json:"received_at"`
+}
+
+// ScanPathCheckFilter narrows ListScanPathChecks.
+type ScanPathCheckFilter struct {
+	Serial    string
+	AgentIDs  []string // Empty = all agents
+	Unmatched bool     // Only messages not tied to a device
+	Limit     int
+}
@@ -0,0 +1,85 @@
+package storage
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Share link kinds.
+const (
+	ShareLinkKindDevices = "devices" // filtered device view of the tenant's fleet
+	ShareLinkKindReport  = "report"  // a single completed report run
+)
+
+// MaxShareLinkTTL caps how long a share link can stay valid.
+const MaxShareLinkTTL = 90 * 24 * time.Hour
+
+// ShareLinkFilter narrows the devices shown by a devices share link. Empty
+// fields match everything; text fields are case-insensitive substrings.
+type ShareLinkFilter struct {
+	AgentIDs     []string `json:"agent_ids,omitempty"`
+	Manufacturer string   `json:"manufacturer,omitempty"`
+	Model        string   `json:"model,omitempty"`
+	Location     string   `json:"location,omitempty"`
+	Search       string   `json:"search,omitempty"`
+}
+
+// ShareLink is a time-limited, read-only link to a snapshot of one tenant's
+// data for people without an account. Only a hash of its token is stored.
+type ShareLink struct {
+	ID             int64           `json:"id"`
+	TenantID       string          `json:"tenant_id"`
+	Kind           string          `json:"kind"`
+	Name           string          `json:"name,omitempty"`
+	Filter         ShareLinkFilter `json:"filter"`
+	ReportRunID    *int64          `json:"report_run_id,omitempty"`
+	TokenHash      string          `json:"-"`
+	PasswordHash   string          `json:"-"`
+	HasPassword    bool            `json:"has_password"`
+	ExpiresAt      time.Time       `json:"expires_at"`
+	RevokedAt      *time.Time      `json:"revoked_at,omitempty"`
+	RevokedBy      string          `json:"revoked_by,omitempty"`
+	AccessCount    int64           `json:"access_count"`
+	LastAccessedAt *time.Time      `json:"last_accessed_at,omitempty"`
+	CreatedBy      string          `json:"created_by,omitempty"`
+	CreatedAt      time.Time       `json:"created_at"`
+}
+
+// Validate checks a new share link before it is stored.
+func (l *ShareLink) Validate() error {
+	l.TenantID = strings.TrimSpace(l.TenantID)
+	l.Name = strings.TrimSpace(l.Name)
+	if l.TenantID == "" {
+		return fmt.Errorf("tenant_id is required")
+	}
+	switch l.Kind {
+	case ShareLinkKindDevices:
+		l.ReportRunID = nil
+	case ShareLinkKindReport:
+		if l.ReportRunID == nil || *l.ReportRunID <= 0 {
+			return fmt.Errorf("report_run_id is required for report links")
+		}
+	default:
+		return fmt.Errorf("kind must be %q or %q", ShareLinkKindDevices, ShareLinkKindReport)
+	}
+	if l.ExpiresAt.IsZero() {
+		return fmt.Errorf("expires_at is required")
+	}
+	return nil
+}
+
+// Active reports whether the link can still be used at now.
+func (l *ShareLink) Active(now time.Time) bool {
+	return l.RevokedAt == nil && now.Before(l.ExpiresAt)
+}
+
+// CheckPassword reports whether password unlocks the link. Links without a
+// password accept anything.
+func (l *ShareLink) CheckPassword(password string) bool {
+	if l.PasswordHash == "" {
+		return true
+	}
+	ok, err := verifyArgonHash(password, l.PasswordHash)
+	return err == nil && ok
+}
@@ -0,0 +1,75 @@
+package storage
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestShareLinks(t *testing.T) {
+	t.Parallel()
+	s, err := NewSQLiteStore(":memory:")
+	if err != nil {
+		t.Fatalf("NewSQLiteStore: %v", err)
+	}
+	defer s.Close()
+	ctx := context.Background()
+	now := time.Now().UTC()
+
+	runID := int64(42)
+	if _, err := s.CreateShareLink(ctx, &ShareLink{TenantID: "t1", Kind: ShareLinkKindReport, ExpiresAt: now.Add(time.Hour)}, ""); err == nil {
+		t.Fatal("expected report link without report_run_id to be rejected")
+	}
+	report := &ShareLink{TenantID: "t1", Kind: ShareLinkKindReport, ReportRunID: &runID, ExpiresAt: now.Add(time.Hour)}
+	reportToken, err := s.CreateShareLink(ctx, report, "")
+	if err != nil {
+		t.Fatalf("CreateShareLink: %v", err)
+	}
+	devices := &ShareLink{TenantID: "t2", Kind: ShareLinkKindDevices, Name: "Fleet",
+		Filter: ShareLinkFilter{Manufacturer: "HP"}, ExpiresAt: now.Add(-time.Minute)}
+	devicesToken, err := s.CreateShareLink(ctx, devices, "pw")
+	if err != nil {
+		t.Fatalf("CreateShareLink: %v", err)
+	}
+
+	got, err := s.GetShareLinkByToken(ctx, reportToken)
+	if err != nil || got == nil || got.ID != report.ID || got.ReportRunID == nil || *got.ReportRunID != runID {
+		t.Fatalf("GetShareLinkByToken: %+v, %v", got, err)
+	}
+	if !got.Active(now) || !got.CheckPassword("anything") {
+		t.Fatalf("expected active link without password, got %+v", got)
+	}
+
+	expired, err := s.GetShareLinkByToken(ctx, devicesToken)
+	if err != nil || expired == nil || expired.Filter.Manufacturer != "HP" || !expired.HasPassword {
+		t.Fatalf("GetShareLinkByToken: %+v, %v", expired, err)
+	}
+	if expired.Active(now) {
+		t.Fatal("expected expired link to be inactive")
+	}
+	if expired.CheckPassword("wrong") || !expired.CheckPassword("pw") {
+		t.Fatal("password check mismatch")
+	}
+	if none, err := s.GetShareLinkByToken(ctx, "unknown"); err != nil || none != nil {
+		t.Fatalf("expected no link for unknown token, got %+v, %v", none, err)
+	}
+
+	if err := s.RevokeShareLink(ctx, report.ID, "admin"); err != nil {
+		t.Fatalf("RevokeShareLink: %v", err)
+	}
+	if err := s.RevokeShareLink(ctx, 999, "admin"); err == nil {
+		t.Fatal("expected error revoking unknown link")
+	}
+	revoked, _ := s.GetShareLink(ctx, report.ID)
+	if revoked == nil || revoked.RevokedAt == nil || revoked.RevokedBy != "admin" || revoked.Active(now) {
+		t.Fatalf("expected revoked link, got %+v", revoked)
+	}
+
+	links, err := s.ListShareLinks(ctx, []string{"t2"})
+	if err != nil || len(links) != 1 || links[0].ID != devices.ID {
+		t.Fatalf("ListShareLinks(t2): %+v, %v", links, err)
+	}
+	if all, err := s.ListShareLinks(ctx, nil); err != nil || len(all) != 2 {
+		t.Fatalf("ListShareLinks(all): %d, %v", len(all), err)
+	}
+}
@@ -114,7 +114,14 @@ func (s *SQLiteStore) initSchema() error {
 		device_count INTEGER DEFAULT 0,
 		last_device_sync DATETIME,
 		last_metrics_sync DATETIME,
-		tenant_id TEXT
+		tenant_id TEXT,
+		token_issued_at DATETIME,
+		token_expires_at DATETIME,
+		previous_token TEXT,
+		previous_token_expires_at DATETIME,
+		token_rotation_requested INTEGER NOT NULL DEFAULT 0,
+		token_resend_nonce TEXT,
+		runtime_environment TEXT
 	);
 
 	CREATE INDEX IF NOT EXISTS idx_agents_agent_id ON agents(agent_id);
@@ -173,6 +180,9 @@ func (s *SQLiteStore) initSchema() error {
 		color_pages INTEGER DEFAULT 0,
 		mono_pages INTEGER DEFAULT 0,
 		scan_count INTEGER DEFAULT 0,
+		duplex_sheets INTEGER DEFAULT 0,
+		simplex_sheets INTEGER DEFAULT 0,
+		nup_sheets INTEGER DEFAULT 0,
 		toner_levels TEXT,
 		tenant_id TEXT,
 		FOREIGN KEY(serial) REFERENCES devices(serial) ON DELETE CASCADE,
@@ -234,6 +244,7 @@ func (s *SQLiteStore) initSchema() error {
 		billing_code TEXT,
 		address TEXT,
 		login_domain TEXT,
+		kind TEXT,
 		created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
 	);
 
@@ -302,6 +313,588 @@ func (s *SQLiteStore) initSchema() error {
 	CREATE INDEX IF NOT EXISTS idx_pending_registrations_tenant ON pending_agent_registrations(expired_tenant_id);
 	CREATE INDEX IF NOT EXISTS idx_pending_registrations_agent ON pending_agent_registrations(agent_id);
 
+	-- Device approval workflow (newly discovered devices awaiting operator review)
+	CREATE TABLE IF NOT EXISTS device_approvals (
+		serial TEXT PRIMARY KEY,
+		tenant_id TEXT,
+		agent_id TEXT NOT NULL,
+		status TEXT NOT NULL DEFAULT 'pending',
+		manufacturer TEXT,
+		model TEXT,
+		ip TEXT,
+		reason TEXT,
+		created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+		reviewed_at DATETIME,
+		reviewed_by TEXT,
+		notes TEXT
+	);
+
+	CREATE INDEX IF NOT EXISTS idx_device_approvals_status ON device_approvals(status);
+	CREATE INDEX IF NOT EXISTS idx_device_approvals_tenant ON device_approvals(tenant_id);
+
+	CREATE TABLE IF NOT EXISTS device_approval_policies (
+		tenant_id TEXT PRIMARY KEY,
+		enabled INTEGER NOT NULL DEFAULT 0,
+		auto_approve_manufacturers TEXT,
+		auto_approve_models TEXT,
+		updated_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+		updated_by TEXT
+	);
+
+	-- Onboarding checklist steps marked done or skipped by an operator
+	CREATE TABLE IF NOT EXISTS tenant_onboarding (
+		tenant_id TEXT NOT NULL,
+		step TEXT NOT NULL,
+		status TEXT NOT NULL,
+		updated_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+		updated_by TEXT,
+		PRIMARY KEY (tenant_id, step),
+		FOREIGN KEY(tenant_id) REFERENCES tenants(id) ON DELETE CASCADE
+	);
+
+	-- Customized tenant dashboard layouts (tenants without a row use the default for their kind)
+	CREATE TABLE IF NOT EXISTS tenant_dashboards (
+		tenant_id TEXT PRIMARY KEY,
+		layout TEXT NOT NULL,
+		updated_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+		updated_by TEXT,
+		FOREIGN KEY(tenant_id) REFERENCES tenants(id) ON DELETE CASCADE
+	);
+
+	-- Admin-defined device status normalization rules (evaluated before built-in defaults)
+	CREATE TABLE IF NOT EXISTS device_status_rules (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		position INTEGER NOT NULL DEFAULT 0,
+		vendor TEXT,
+		pattern TEXT NOT NULL,
+		state TEXT NOT NULL,
+		description TEXT,
+		created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+	);
+
+	-- Cartridge baseline library: rated yields per printer model and supply
+	CREATE TABLE IF NOT EXISTS yield_baselines (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		position INTEGER NOT NULL DEFAULT 0,
+		printer_model TEXT NOT NULL,
+		supply TEXT NOT NULL,
+		cartridge_model TEXT,
+		supplier TEXT,
+		rated_yield INTEGER NOT NULL,
+		cartridge_cost REAL,
+		notes TEXT,
+		created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+	);
+
+	-- Server-scheduled deep scans (full SNMP walk + parse capture across agents)
+	CREATE TABLE IF NOT EXISTS deep_scan_schedules (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		name TEXT NOT NULL,
+		enabled INTEGER NOT NULL DEFAULT 1,
+		agent_ids TEXT,
+		ranges TEXT,
+		include_saved INTEGER NOT NULL DEFAULT 1,
+		frequency TEXT NOT NULL DEFAULT 'daily',
+		day_of_week INTEGER NOT NULL DEFAULT 0,
+		time_of_day TEXT NOT NULL DEFAULT '02:00',
+		timezone TEXT NOT NULL DEFAULT 'UTC',
+		next_run_at DATETIME NOT NULL,
+		last_run_at DATETIME,
+		last_run_id INTEGER,
+		created_by TEXT,
+		created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+		updated_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+	);
+
+	CREATE TABLE IF NOT EXISTS deep_scan_runs (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		schedule_id INTEGER,
+		name TEXT,
+		status TEXT NOT NULL,
+		triggered_by TEXT,
+		started_at DATETIME NOT NULL,
+		completed_at DATETIME,
+		agents_total INTEGER NOT NULL DEFAULT 0,
+		agents_completed INTEGER NOT NULL DEFAULT 0,
+		agents_failed INTEGER NOT NULL DEFAULT 0,
+		devices_scanned INTEGER NOT NULL DEFAULT 0,
+		devices_failed INTEGER NOT NULL DEFAULT 0,
+		devices_changed INTEGER NOT NULL DEFAULT 0
+	);
+	CREATE INDEX IF NOT EXISTS idx_deep_scan_runs_schedule ON deep_scan_runs(schedule_id, started_at);
+
+	CREATE TABLE IF NOT EXISTS deep_scan_agent_results (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		run_id INTEGER NOT NULL REFERENCES deep_scan_runs(id) ON DELETE CASCADE,
+		agent_id TEXT NOT NULL,
+		status TEXT NOT NULL,
+		job_id TEXT,
+		total INTEGER NOT NULL DEFAULT 0,
+		done INTEGER NOT NULL DEFAULT 0,
+		failed INTEGER NOT NULL DEFAULT 0,
+		error TEXT,
+		results_json TEXT,
+		diffs_json TEXT,
+		started_at DATETIME,
+		completed_at DATETIME
+	);
+	CREATE INDEX IF NOT EXISTS idx_deep_scan_agent_results_run ON deep_scan_agent_results(run_id);
+	CREATE INDEX IF NOT EXISTS idx_deep_scan_agent_results_agent ON deep_scan_agent_results(agent_id, run_id);
+
+	-- Read-only share links to tenant snapshots (token stored hashed)
+	CREATE TABLE IF NOT EXISTS share_links (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		tenant_id TEXT NOT NULL,
+		kind TEXT NOT NULL,
+		name TEXT,
+		filter_json TEXT,
+		report_run_id INTEGER,
+		token_hash TEXT NOT NULL UNIQUE,
+		password_hash TEXT,
+		expires_at DATETIME NOT NULL,
+		revoked_at DATETIME,
+		revoked_by TEXT,
+		access_count INTEGER NOT NULL DEFAULT 0,
+		last_accessed_at DATETIME,
+		created_by TEXT,
+		created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+	);
+	CREATE INDEX IF NOT EXISTS idx_share_links_tenant ON share_links(tenant_id, created_at);
+
+	-- Latest settings reported by each agent compared against its policy
+	CREATE TABLE IF NOT EXISTS agent_config_drift (
+		agent_id TEXT PRIMARY KEY,
+		tenant_id TEXT,
+		expected_version TEXT,
+		reported_version TEXT,
+		drifted INTEGER NOT NULL DEFAULT 0,
+		fields_json TEXT,
+		checked_at DATETIME NOT NULL,
+		drift_since DATETIME,
+		enforce_requested_at DATETIME,
+		enforce_requested_by TEXT,
+		last_enforced_at DATETIME
+	);
+	CREATE INDEX IF NOT EXISTS idx_agent_config_drift_tenant ON agent_config_drift(tenant_id, drifted);
+
+	CREATE TABLE IF NOT EXISTS agent_config_drift_events (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		agent_id TEXT NOT NULL,
+		tenant_id TEXT,
+		event TEXT NOT NULL,
+		fields_json TEXT,
+		expected_version TEXT,
+		reported_version TEXT,
+		actor TEXT,
+		created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+	);
+	CREATE INDEX IF NOT EXISTS idx_agent_config_drift_events_agent ON agent_config_drift_events(agent_id, created_at);
+
+	-- User-authored device notes and maintenance log entries
+	CREATE TABLE IF NOT EXISTS device_notes (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		serial TEXT NOT NULL,
+		tenant_id TEXT,
+		kind TEXT NOT NULL,
+		body TEXT,
+		part TEXT,
+		vendor TEXT,
+		firmware_from TEXT,
+		firmware_to TEXT,
+		page_count INTEGER,
+		author TEXT NOT NULL,
+		occurred_at DATETIME NOT NULL,
+		created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+		updated_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+	);
+	CREATE INDEX IF NOT EXISTS idx_device_notes_serial ON device_notes(serial, occurred_at);
+	CREATE INDEX IF NOT EXISTS idx_device_notes_tenant ON device_notes(tenant_id, occurred_at);
+
+	-- Incidents: printer problems tracked through to resolution
+	CREATE TABLE IF NOT EXISTS incidents (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		tenant_id TEXT,
+		title TEXT NOT NULL,
+		description TEXT,
+		severity TEXT NOT NULL,
+		status TEXT NOT NULL,
+		assignee TEXT,
+		device_serial TEXT,
+		agent_id TEXT,
+		source TEXT NOT NULL,
+		correlation_key TEXT,
+		created_by TEXT,
+		opened_at DATETIME NOT NULL,
+		updated_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+		resolved_at DATETIME
+	);
+	CREATE INDEX IF NOT EXISTS idx_incidents_status ON incidents(status, opened_at);
+	CREATE INDEX IF NOT EXISTS idx_incidents_tenant ON incidents(tenant_id, status);
+	CREATE INDEX IF NOT EXISTS idx_incidents_key ON incidents(correlation_key);
+
+	CREATE TABLE IF NOT EXISTS incident_alerts (
+		incident_id INTEGER NOT NULL,
+		alert_id INTEGER NOT NULL,
+		linked_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+		PRIMARY KEY (incident_id, alert_id)
+	);
+	CREATE INDEX IF NOT EXISTS idx_incident_alerts_alert ON incident_alerts(alert_id);
+
+	CREATE TABLE IF NOT EXISTS incident_notes (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		incident_id INTEGER NOT NULL,
+		kind TEXT NOT NULL,
+		body TEXT NOT NULL,
+		author TEXT NOT NULL,
+		created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+	);
+	CREATE INDEX IF NOT EXISTS idx_incident_notes_incident ON incident_notes(incident_id, created_at);
+
+	-- Parse-debug samples uploaded by agents for devices with parsing gaps
+	CREATE TABLE IF NOT EXISTS parse_samples (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		agent_id TEXT NOT NULL,
+		tenant_id TEXT,
+		ip TEXT NOT NULL,
+		serial TEXT,
+		manufacturer TEXT,
+		model TEXT,
+		sys_object_id TEXT,
+		missing_json TEXT,
+		steps_json TEXT,
+		raw_pdus_json TEXT,
+		pdu_count INTEGER NOT NULL DEFAULT 0,
+		captured_at DATETIME NOT NULL,
+		received_at DATETIME NOT NULL,
+		UNIQUE(agent_id, ip)
+	);
+	CREATE INDEX IF NOT EXISTS idx_parse_samples_tenant ON parse_samples(tenant_id, captured_at);
+	CREATE INDEX IF NOT EXISTS idx_parse_samples_group ON parse_samples(manufacturer, sys_object_id);
+
+	-- Anonymized samples of printers agents could not identify (opt-in telemetry)
+	CREATE TABLE IF NOT EXISTS unknown_device_reports (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		agent_id TEXT NOT NULL,
+		tenant_id TEXT,
+		sys_object_id TEXT NOT NULL DEFAULT '',
+		sys_descr TEXT NOT NULL DEFAULT '',
+		enterprise TEXT,
+		model TEXT,
+		sightings INTEGER NOT NULL DEFAULT 0,
+		first_seen DATETIME NOT NULL,
+		last_seen DATETIME NOT NULL,
+		received_at DATETIME NOT NULL,
+		UNIQUE(agent_id, sys_object_id, sys_descr)
+	);
+	CREATE INDEX IF NOT EXISTS idx_unknown_device_reports_tenant ON unknown_device_reports(tenant_id);
+
+	-- Server-managed vendor detection rules, sent to every agent
+	CREATE TABLE IF NOT EXISTS detection_rules (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		manufacturer TEXT NOT NULL,
+		sys_object_id_prefix TEXT NOT NULL DEFAULT '',
+		sys_descr_regex TEXT NOT NULL DEFAULT '',
+		model_regex TEXT NOT NULL DEFAULT '',
+		vendor_module TEXT NOT NULL DEFAULT '',
+		priority INTEGER NOT NULL DEFAULT 0,
+		enabled INTEGER NOT NULL DEFAULT 1,
+		description TEXT,
+		created_by TEXT,
+		created_at DATETIME NOT NULL,
+		updated_at DATETIME NOT NULL
+	);
+
+	-- Dangerous operations awaiting a second administrator's approval
+	CREATE TABLE IF NOT EXISTS action_approvals (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		action TEXT NOT NULL,
+		tenant_id TEXT,
+		target_type TEXT NOT NULL,
+		target_id TEXT NOT NULL,
+		target_name TEXT,
+		summary TEXT NOT NULL,
+		reason TEXT,
+		payload_json TEXT,
+		status TEXT NOT NULL,
+		requested_by_id INTEGER NOT NULL,
+		requested_by TEXT NOT NULL,
+		decided_by TEXT,
+		decision_note TEXT,
+		result TEXT,
+		created_at DATETIME NOT NULL,
+		expires_at DATETIME NOT NULL,
+		decided_at DATETIME,
+		executed_at DATETIME
+	);
+	CREATE INDEX IF NOT EXISTS idx_action_approvals_status ON action_approvals(status, created_at);
+	CREATE INDEX IF NOT EXISTS idx_action_approvals_target ON action_approvals(target_id, action);
+
+	-- Customized alert notification templates (tenant_id '' = global default)
+	CREATE TABLE IF NOT EXISTS notification_templates (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		tenant_id TEXT NOT NULL DEFAULT '',
+		channel_type TEXT NOT NULL,
+		subject TEXT,
+		text_body TEXT NOT NULL,
+		html_body TEXT,
+		updated_by TEXT,
+		created_at DATETIME NOT NULL,
+		updated_at DATETIME NOT NULL,
+		UNIQUE(tenant_id, channel_type)
+	);
+
+	-- Change feed of device and metrics updates for incremental sync
+	CREATE TABLE IF NOT EXISTS device_changes (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		serial TEXT NOT NULL,
+		agent_id TEXT,
+		kind TEXT NOT NULL,
+		op TEXT NOT NULL,
+		content_hash TEXT,
+		changed_at DATETIME NOT NULL
+	);
+	CREATE INDEX IF NOT EXISTS idx_device_changes_agent ON device_changes(agent_id, id);
+	CREATE INDEX IF NOT EXISTS idx_device_changes_serial ON device_changes(serial, kind, id);
+
+	-- Scan-to-email test messages received from MFPs
+	CREATE TABLE IF NOT EXISTS scan_path_checks (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		serial TEXT,
+		agent_id TEXT,
+		match_method TEXT,
+		verified INTEGER NOT NULL DEFAULT 0,
+		sender TEXT NOT NULL DEFAULT '',
+		recipients TEXT NOT NULL DEFAULT '',
+		subject TEXT NOT NULL DEFAULT '',
+		source_ip TEXT NOT NULL DEFAULT '',
+		attachment_count INTEGER NOT NULL DEFAULT 0,
+		attachment_bytes BIGINT NOT NULL DEFAULT 0,
+		attachments_json TEXT NOT NULL DEFAULT '[]',
+		received_at DATETIME NOT NULL
+	);
+	CREATE INDEX IF NOT EXISTS idx_scan_path_checks_serial ON scan_path_checks(serial, received_at);
+	CREATE INDEX IF NOT EXISTS idx_scan_path_checks_received ON scan_path_checks(received_at);
+
+	-- Per-tenant secrets that sign vendor cloud webhooks
+	CREATE TABLE IF NOT EXISTS vendor_webhook_secrets (
+		tenant_id TEXT NOT NULL,
+		source TEXT NOT NULL,
+		secret TEXT NOT NULL,
+		created_by TEXT,
+		created_at DATETIME NOT NULL,
+		PRIMARY KEY (tenant_id, source)
+	);
+
+	-- Webhooks received from vendor cloud platforms (HP SDS, Lexmark Cloud)
+	CREATE TABLE IF NOT EXISTS vendor_webhook_deliveries (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		tenant_id TEXT NOT NULL DEFAULT '',
+		source TEXT NOT NULL,
+		delivery_id TEXT NOT NULL,
+		status TEXT NOT NULL,
+		error TEXT,
+		events INTEGER NOT NULL DEFAULT 0,
+		matched INTEGER NOT NULL DEFAULT 0,
+		unmatched INTEGER NOT NULL DEFAULT 0,
+		source_ip TEXT,
+		received_at DATETIME NOT NULL
+	);
+	CREATE UNIQUE INDEX IF NOT EXISTS idx_vendor_webhook_deliveries_tenant ON vendor_webhook_deliveries(tenant_id, source, delivery_id);
+	CREATE INDEX IF NOT EXISTS idx_vendor_webhook_deliveries_received ON vendor_webhook_deliveries(received_at);
+
+	-- Alerts, supply levels and counters reported by vendor clouds, with how
+	-- each compares with the agent's reading
+	CREATE TABLE IF NOT EXISTS vendor_readings (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		delivery_id INTEGER NOT NULL,
+		source TEXT NOT NULL,
+		serial TEXT NOT NULL,
+		agent_id TEXT,
+		tenant_id TEXT,
+		kind TEXT NOT NULL,
+		metric TEXT NOT NULL DEFAULT '',
+		value BIGINT NOT NULL DEFAULT 0,
+		severity TEXT,
+		state TEXT,
+		message TEXT,
+		observed_at DATETIME NOT NULL,
+		received_at DATETIME NOT NULL,
+		reconcile TEXT,
+		agent_value BIGINT,
+		agent_observed_at DATETIME,
+		alert_id INTEGER
+	);
+	CREATE INDEX IF NOT EXISTS idx_vendor_readings_serial ON vendor_readings(serial, observed_at);
+	CREATE INDEX IF NOT EXISTS idx_vendor_readings_delivery ON vendor_readings(delivery_id);
+	CREATE INDEX IF NOT EXISTS idx_vendor_readings_received ON vendor_readings(received_at);
+
+	-- Metrics periods moved to cold storage files
+	CREATE TABLE IF NOT EXISTS metrics_archives (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		period_start DATETIME NOT NULL,
+		period_end DATETIME NOT NULL,
+		path TEXT NOT NULL,
+		row_count BIGINT NOT NULL DEFAULT 0,
+		summary_rows BIGINT NOT NULL DEFAULT 0,
+		device_count INTEGER NOT NULL DEFAULT 0,
+		size_bytes BIGINT NOT NULL DEFAULT 0,
+		sha256 TEXT NOT NULL DEFAULT '',
+		created_at DATETIME NOT NULL,
+		restored_at DATETIME,
+		restored_until DATETIME
+	);
+	CREATE INDEX IF NOT EXISTS idx_metrics_archives_period ON metrics_archives(period_start);
+
+	-- Regional relay servers that forward agent uploads (token stored hashed)
+	CREATE TABLE IF NOT EXISTS relays (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		name TEXT NOT NULL UNIQUE,
+		region TEXT NOT NULL DEFAULT '',
+		url TEXT NOT NULL,
+		token_hash TEXT NOT NULL UNIQUE,
+		version TEXT,
+		last_seen DATETIME,
+		queue_depth BIGINT NOT NULL DEFAULT 0,
+		forwarded_total BIGINT NOT NULL DEFAULT 0,
+		dropped_total BIGINT NOT NULL DEFAULT 0,
+		created_by TEXT,
+		created_at DATETIME NOT NULL
+	);
+
+	-- Relay each agent currently uploads through
+	CREATE TABLE IF NOT EXISTS relay_agents (
+		agent_id TEXT PRIMARY KEY,
+		relay_id BIGINT NOT NULL,
+		rtt_ms INTEGER NOT NULL DEFAULT 0,
+		assigned_at DATETIME NOT NULL
+	);
+	CREATE INDEX IF NOT EXISTS idx_relay_agents_relay ON relay_agents(relay_id);
+
+	-- Daily request counts per API token and endpoint
+	CREATE TABLE IF NOT EXISTS api_token_usage (
+		token TEXT NOT NULL,
+		day TEXT NOT NULL,
+		endpoint TEXT NOT NULL,
+		requests BIGINT NOT NULL DEFAULT 0,
+		errors BIGINT NOT NULL DEFAULT 0,
+		last_used_at DATETIME NOT NULL,
+		PRIMARY KEY (token, day, endpoint)
+	);
+	CREATE INDEX IF NOT EXISTS idx_api_token_usage_day ON api_token_usage(day);
+
+	-- Per-user alert notification preferences
+	CREATE TABLE IF NOT EXISTS user_notification_prefs (
+		user_id INTEGER PRIMARY KEY,
+		enabled INTEGER NOT NULL DEFAULT 0,
+		min_severity TEXT NOT NULL DEFAULT 'warning',
+		alert_types TEXT,
+		email_enabled INTEGER NOT NULL DEFAULT 1,
+		email_address TEXT,
+		webhook_url TEXT,
+		frequency TEXT NOT NULL DEFAULT 'immediate',
+		digest_hour INTEGER NOT NULL DEFAULT 8,
+		last_alert_id INTEGER NOT NULL DEFAULT 0,
+		last_digest_at DATETIME,
+		updated_at DATETIME NOT NULL
+	);
+
+	-- Finalized per-tenant monthly billing counters (immutable once written)
+	CREATE TABLE IF NOT EXISTS billing_periods (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		tenant_id TEXT NOT NULL,
+		period TEXT NOT NULL,
+		period_start DATETIME NOT NULL,
+		period_end DATETIME NOT NULL,
+		devices_managed INTEGER NOT NULL DEFAULT 0,
+		pages_mono BIGINT NOT NULL DEFAULT 0,
+		pages_color BIGINT NOT NULL DEFAULT 0,
+		pages_total BIGINT NOT NULL DEFAULT 0,
+		agents_online INTEGER NOT NULL DEFAULT 0,
+		agents_total INTEGER NOT NULL DEFAULT 0,
+		computed_at DATETIME NOT NULL,
+		finalized_at DATETIME NOT NULL,
+		finalized_by TEXT,
+		schema_version INTEGER NOT NULL DEFAULT 1,
+		UNIQUE(tenant_id, period)
+	);
+	CREATE INDEX IF NOT EXISTS idx_billing_periods_period ON billing_periods(period);
+
+	-- Certified meter reads uploaded by agents (signed, never updated)
+	CREATE TABLE IF NOT EXISTS meter_reads (
+		id TEXT PRIMARY KEY,
+		serial TEXT NOT NULL,
+		agent_id TEXT NOT NULL,
+		tenant_id TEXT,
+		ip TEXT,
+		manufacturer TEXT,
+		model TEXT,
+		period TEXT NOT NULL,
+		trigger TEXT NOT NULL,
+		read_at DATETIME NOT NULL,
+		page_count BIGINT NOT NULL DEFAULT 0,
+		mono_pages BIGINT NOT NULL DEFAULT 0,
+		color_pages BIGINT NOT NULL DEFAULT 0,
+		scan_count BIGINT NOT NULL DEFAULT 0,
+		confirmation TEXT,
+		raw_oids TEXT,
+		signature_alg TEXT,
+		key_id TEXT,
+		signature TEXT,
+		received_at DATETIME NOT NULL
+	);
+	CREATE INDEX IF NOT EXISTS idx_meter_reads_serial ON meter_reads(serial, read_at);
+	CREATE INDEX IF NOT EXISTS idx_meter_reads_period ON meter_reads(period, tenant_id);
+
+	-- Public keys agents sign meter reads with; kept after replacement so
+	-- reads queued before a key change still verify
+	CREATE TABLE IF NOT EXISTS agent_signing_keys (
+		agent_id TEXT NOT NULL,
+		key_id TEXT NOT NULL,
+		public_key TEXT NOT NULL,
+		created_at DATETIME NOT NULL,
+		PRIMARY KEY (agent_id, key_id)
+	);
+
+	-- Meter read anomalies flagged during reconciliation, with review outcome
+	CREATE TABLE IF NOT EXISTS meter_read_anomalies (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		read_id TEXT NOT NULL,
+		serial TEXT NOT NULL,
+		tenant_id TEXT,
+		period TEXT NOT NULL,
+		kind TEXT NOT NULL,
+		details TEXT,
+		status TEXT NOT NULL DEFAULT 'open',
+		adjusted_mono BIGINT NOT NULL DEFAULT 0,
+		adjusted_color BIGINT NOT NULL DEFAULT 0,
+		note TEXT,
+		resolved_by TEXT,
+		resolved_at DATETIME,
+		created_at DATETIME NOT NULL,
+		UNIQUE(read_id, kind)
+	);
+	CREATE INDEX IF NOT EXISTS idx_meter_read_anomalies_period ON meter_read_anomalies(period, status);
+
+	-- Agent self-update rollout records built from update telemetry
+	CREATE TABLE IF NOT EXISTS agent_update_runs (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		agent_id TEXT NOT NULL,
+		tenant_id TEXT,
+		run_id TEXT NOT NULL,
+		status TEXT NOT NULL,
+		current_version TEXT,
+		target_version TEXT,
+		error_code TEXT,
+		error_message TEXT,
+		error_signature TEXT,
+		diagnostics TEXT,
+		metadata_json TEXT,
+		started_at DATETIME NOT NULL,
+		updated_at DATETIME NOT NULL,
+		UNIQUE(agent_id, run_id)
+	);
+	CREATE INDEX IF NOT EXISTS idx_agent_update_runs_status ON agent_update_runs(status, updated_at);
+
 	-- Local users for UI and API authentication
 	CREATE TABLE IF NOT EXISTS users (
 		id INTEGER PRIMARY KEY AUTOINCREMENT,
@@ -332,6 +925,12 @@ func (s *SQLiteStore) initSchema() error {
 		user_id INTEGER NOT NULL,
 		expires_at DATETIME NOT NULL,
 		created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+		last_seen_at DATETIME,
+		ip_address TEXT,
+		user_agent TEXT,
+		kind TEXT NOT NULL DEFAULT 'web',
+		name TEXT,
+		expiry_warned_at DATETIME,
 		FOREIGN KEY(user_id) REFERENCES users(id) ON DELETE CASCADE
 	);
 
@@ -438,6 +1037,16 @@ func (s *SQLiteStore) initSchema() error {
 		FOREIGN KEY(agent_id) REFERENCES agents(agent_id) ON DELETE CASCADE
 	);
 
+	-- Feature flags (tenant_id '' = server-wide default)
+	CREATE TABLE IF NOT EXISTS feature_flags (
+		tenant_id TEXT NOT NULL DEFAULT '',
+		flag TEXT NOT NULL,
+		enabled INTEGER NOT NULL DEFAULT 0,
+		updated_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+		updated_by TEXT,
+		PRIMARY KEY (tenant_id, flag)
+	);
+
 	-- Fleet update policy table (per-tenant auto-update configuration)
 	CREATE TABLE IF NOT EXISTS fleet_update_policies (
 		tenant_id TEXT PRIMARY KEY,
@@ -777,6 +1386,9 @@ func (s *SQLiteStore) initSchema() error {
 		result_data TEXT,
 		error_message TEXT,
 		run_by TEXT,
+		delivered_to TEXT,
+		delivered_at DATETIME,
+		delivery_error TEXT,
 		created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
 	);
 
@@ -843,6 +1455,7 @@ func (s *SQLiteStore) runMigrations() error {
 		"ALTER TABLE tenants ADD COLUMN billing_code TEXT",
 		"ALTER TABLE tenants ADD COLUMN address TEXT",
 		"ALTER TABLE tenants ADD COLUMN login_domain TEXT",
+		"ALTER TABLE tenants ADD COLUMN kind TEXT",
 		"ALTER TABLE users ADD COLUMN role TEXT NOT NULL DEFAULT 'user'",
 		"ALTER TABLE agents ADD COLUMN name TEXT NOT NULL DEFAULT ''",
 		"ALTER TABLE agents ADD COLUMN os_version TEXT",
@@ -866,6 +1479,31 @@ func (s *SQLiteStore) runMigrations() error {
 		"ALTER TABLE devices ADD COLUMN is_default INTEGER DEFAULT 0",
 		"ALTER TABLE devices ADD COLUMN is_shared INTEGER DEFAULT 0",
 		"ALTER TABLE devices ADD COLUMN spooler_status TEXT",
+		"ALTER TABLE sessions ADD COLUMN last_seen_at DATETIME",
+		"ALTER TABLE sessions ADD COLUMN ip_address TEXT",
+		"ALTER TABLE sessions ADD COLUMN user_agent TEXT",
+		"ALTER TABLE sessions ADD COLUMN kind TEXT NOT NULL DEFAULT 'web'",
+		"ALTER TABLE sessions ADD COLUMN name TEXT",
+		"ALTER TABLE sessions ADD COLUMN expiry_warned_at DATETIME",
+		// agent token rotation
+		"ALTER TABLE agents ADD COLUMN token_issued_at DATETIME",
+		"ALTER TABLE agents ADD COLUMN token_expires_at DATETIME",
+		"ALTER TABLE agents ADD COLUMN previous_token TEXT",
+		"ALTER TABLE agents ADD COLUMN previous_token_expires_at DATETIME",
+		"ALTER TABLE agents ADD COLUMN token_rotation_requested INTEGER NOT NULL DEFAULT 0",
+		"ALTER TABLE agents ADD COLUMN token_resend_nonce TEXT",
+		"ALTER TABLE agents ADD COLUMN runtime_environment TEXT",
+		"ALTER TABLE meter_reads ADD COLUMN key_id TEXT",
+		// cartridge prices for supply cost estimates
+		"ALTER TABLE yield_baselines ADD COLUMN cartridge_cost REAL",
+		// duplex counter for sustainability reports
+		"ALTER TABLE metrics_history ADD COLUMN duplex_sheets INTEGER DEFAULT 0",
+		"ALTER TABLE metrics_history ADD COLUMN simplex_sheets INTEGER DEFAULT 0",
+		"ALTER TABLE metrics_history ADD COLUMN nup_sheets INTEGER DEFAULT 0",
+		// email delivery of scheduled reports
+		"ALTER TABLE report_runs ADD COLUMN delivered_to TEXT",
+		"ALTER TABLE report_runs ADD COLUMN delivered_at DATETIME",
+		"ALTER TABLE report_runs ADD COLUMN delivery_error TEXT",
 	}
 
 	for _, stmt := range altStmts {
@@ -887,6 +1525,10 @@ func (s *SQLiteStore) runMigrations() error {
 		logDebug("SQLite migration statement applied (or already present)", "stmt", "CREATE UNIQUE INDEX idx_tenants_login_domain")
 	}
 
+	if _, err := s.db.Exec(`CREATE INDEX IF NOT EXISTS idx_agents_previous_token ON agents(previous_token)`); err != nil {
+		logWarn("SQLite migration statement (ignored error)", "stmt", "CREATE INDEX idx_agents_previous_token", "error", err)
+	}
+
 	// Data migrations
 	s.backfillUserTenantMappings()
 	s.migrateLegacyRoles()
@@ -0,0 +1,147 @@
+package storage
+
+import (
+	"context"
+	"testing"
+)
+
+func TestNormalizeTenantKind(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		in   string
+		want string
+		ok   bool
+	}{
+		{"", "", true},
+		{" Internal ", TenantKindInternal, true},
+		{"MSP_CUSTOMER", TenantKindMSPCustomer, true},
+		{"reseller", "", false},
+	}
+	for _, tt := range tests {
+		got, ok := NormalizeTenantKind(tt.in)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("NormalizeTenantKind(%q) = %q, %v; want %q, %v", tt.in, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestTenantKindRoundTrip(t *testing.T) {
+	t.Parallel()
+	s, err := NewSQLiteStore(":memory:")
+	if err != nil {
+		t.Fatalf("NewSQLiteStore: %v", err)
+	}
+	defer s.Close()
+
+	ctx := context.Background()
+	tenant := &Tenant{ID: "t1", Name: "Acme", Kind: TenantKindInternal}
+	if err := s.CreateTenant(ctx, tenant); err != nil {
+		t.Fatalf("CreateTenant: %v", err)
+	}
+	got, err := s.GetTenant(ctx, "t1")
+	if err != nil {
+		t.Fatalf("GetTenant: %v", err)
+	}
+	if got.Kind != TenantKindInternal {
+		t.Fatalf("Kind = %q, want %q", got.Kind, TenantKindInternal)
+	}
+
+	got.Kind = TenantKindMSPCustomer
+	if err := s.UpdateTenant(ctx, got); err != nil {
+		t.Fatalf("UpdateTenant: %v", err)
+	}
+	tenants, err := s.ListTenants(ctx)
+	if err != nil {
+		t.Fatalf("ListTenants: %v", err)
+	}
+	if len(tenants) != 1 || tenants[0].Kind != TenantKindMSPCustomer {
+		t.Fatalf("ListTenants = %+v, want one msp_customer tenant", tenants)
+	}
+}
+
+func TestTenantOnboardingSteps(t *testing.T) {
+	t.Parallel()
+	s, err := NewSQLiteStore(":memory:")
+	if err != nil {
+		t.Fatalf("NewSQLiteStore: %v", err)
+	}
+	defer s.Close()
+
+	ctx := context.Background()
+	if err := s.CreateTenant(ctx, &Tenant{ID: "t1", Name: "Acme"}); err != nil {
+		t.Fatalf("CreateTenant: %v", err)
+	}
+
+	if err := s.SetTenantOnboardingStep(ctx, &TenantOnboardingStep{TenantID: "t1", Step: "invite_users", Status: "maybe"}); err == nil {
+		t.Fatal("expected error for invalid status")
+	}
+	if err := s.SetTenantOnboardingStep(ctx, &TenantOnboardingStep{TenantID: "t1", Step: "invite_users", Status: OnboardingStepSkipped, UpdatedBy: "admin"}); err != nil {
+		t.Fatalf("SetTenantOnboardingStep: %v", err)
+	}
+	if err := s.SetTenantOnboardingStep(ctx, &TenantOnboardingStep{TenantID: "t1", Step: "invite_users", Status: OnboardingStepDone, UpdatedBy: "admin"}); err != nil {
+		t.Fatalf("SetTenantOnboardingStep (update): %v", err)
+	}
+
+	steps, err := s.ListTenantOnboardingSteps(ctx, "t1")
+	if err != nil {
+		t.Fatalf("ListTenantOnboardingSteps: %v", err)
+	}
+	if len(steps) != 1 || steps[0].Status != OnboardingStepDone || steps[0].UpdatedBy != "admin" {
+		t.Fatalf("steps = %+v, want one done step", steps)
+	}
+
+	if err := s.ClearTenantOnboardingStep(ctx, "t1", "invite_users"); err != nil {
+		t.Fatalf("ClearTenantOnboardingStep: %v", err)
+	}
+	steps, err = s.ListTenantOnboardingSteps(ctx, "t1")
+	if err != nil {
+		t.Fatalf("ListTenantOnboardingSteps: %v", err)
+	}
+	if len(steps) != 0 {
+		t.Fatalf("steps after clear = %+v, want none", steps)
+	}
+}
+
+func TestTenantDashboardLifecycle(t *testing.T) {
+	t.Parallel()
+	s, err := NewSQLiteStore(":memory:")
+	if err != nil {
+		t.Fatalf("NewSQLiteStore: %v", err)
+	}
+	defer s.Close()
+
+	ctx := context.Background()
+	if err := s.CreateTenant(ctx, &Tenant{ID: "t1", Name: "Acme"}); err != nil {
+		t.Fatalf("CreateTenant: %v", err)
+	}
+
+	d, err := s.GetTenantDashboard(ctx, "t1")
+	if err != nil {
+		t.Fatalf("GetTenantDashboard (none): %v", err)
+	}
+	if d != nil {
+		t.Fatalf("expected no dashboard, got %+v", d)
+	}
+
+	if err := s.UpsertTenantDashboard(ctx, &TenantDashboard{TenantID: "t1", Layout: DashboardLayout{Widgets: []string{"devices", "tree"}}}); err != nil {
+		t.Fatalf("UpsertTenantDashboard: %v", err)
+	}
+	if err := s.UpsertTenantDashboard(ctx, &TenantDashboard{TenantID: "t1", Layout: DashboardLayout{Widgets: []string{"tree"}}, UpdatedBy: "admin"}); err != nil {
+		t.Fatalf("UpsertTenantDashboard (update): %v", err)
+	}
+	d, err = s.GetTenantDashboard(ctx, "t1")
+	if err != nil {
+		t.Fatalf("GetTenantDashboard: %v", err)
+	}
+	if d == nil || len(d.Layout.Widgets) != 1 || d.Layout.Widgets[0] != "tree" || d.UpdatedBy != "admin" {
+		t.Fatalf("dashboard = %+v, want [tree] by admin", d)
+	}
+
+	if err := s.DeleteTenant(ctx, "t1"); err != nil {
+		t.Fatalf("DeleteTenant: %v", err)
+	}
+	if d, err := s.GetTenantDashboard(ctx, "t1"); err != nil || d != nil {
+		t.Fatalf("dashboard after tenant delete = %+v, %v; want nil", d, err)
+	}
+}
@@ -0,0 +1,56 @@
+package storage
+
+import (
+	"strings"
+	"time"
+)
+
+// Tenant kinds. The kind picks the dashboard layout a new tenant starts with;
+// tenants created before kinds existed have an empty kind and are treated as
+// MSP customers.
+const (
+	TenantKindMSPCustomer = "msp_customer"
+	TenantKindInternal    = "internal"
+)
+
+// NormalizeTenantKind lowercases and trims a tenant kind. The second return
+// value is false when the kind is not recognized; an empty kind is valid.
+func NormalizeTenantKind(value string) (string, bool) {
+	kind := strings.ToLower(strings.TrimSpace(value))
+	switch kind {
+	case "", TenantKindMSPCustomer, TenantKindInternal:
+		return kind, true
+	}
+	return "", false
+}
+
+// Onboarding step statuses an operator can set by hand. Steps without a
+// stored status are judged from the tenant's data.
+const (
+	OnboardingStepDone    = "done"
+	OnboardingStepSkipped = "skipped"
+)
+
+// TenantOnboardingStep records an onboarding checklist step that was marked
+// done or skipped manually.
+type TenantOnboardingStep struct {
+	TenantID  string    `json:"tenant_id"`
+	Step      string    `json:"step"`
+	Status    string    `json:"status"`
+	UpdatedAt time.Time `json:"updated_at"`
+	UpdatedBy string    `json:"updated_by,omitempty"`
+}
+
+// DashboardLayout lists the dashboard widgets to show, in display order.
+// Widgets not listed are hidden.
+type DashboardLayout struct {
+	Widgets []string `json:"widgets"`
+}
+
+// TenantDashboard is a tenant's customized dashboard layout.
+type TenantDashboard struct {
+	TenantID  string          `json:"tenant_id"`
+	Layout    DashboardLayout `json:"layout"`
+	UpdatedAt time.Time       `json:"updated_at"`
+	UpdatedBy string          `json:"updated_by,omitempty"`
+}
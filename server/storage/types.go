@@ -7,6 +7,7 @@ import (
 	"net"
 	pmsettings "printmaster/common/settings"
 	commonstorage "printmaster/common/storage"
+	"printmaster/server/devicestatus"
 	"regexp"
 	"sort"
 	"strings"
@@ -21,6 +22,8 @@ var (
 	ErrTokenExpired = errors.New("token expired")
 	// ErrTokenRevoked indicates a known token that was manually revoked
 	ErrTokenRevoked = errors.New("token revoked")
+	// ErrAgentTokenExpired indicates an agent bearer token past its lifetime
+	ErrAgentTokenExpired = errors.New("agent token expired")
 )
 
 // TokenValidationError wraps a token error with the original tenant for expired tokens
@@ -54,9 +57,15 @@ func IsExpiredToken(err error) bool {
 type Role string
 
 const (
-	RoleAdmin    Role = "admin"
-	RoleOperator Role = "operator"
-	RoleViewer   Role = "viewer"
+	RoleAdmin Role = "admin"
+	// RoleTenantAdmin manages users, join tokens and settings of the tenants
+	// assigned to it, on top of operator permissions
+	RoleTenantAdmin Role = "tenant_admin"
+	RoleOperator    Role = "operator"
+	// RoleAuditor reads audit logs and reports of its tenants but cannot
+	// change anything
+	RoleAuditor Role = "auditor"
+	RoleViewer  Role = "viewer"
 )
 
 // NormalizeRole ensures any persisted or user-provided role maps to a known value.
@@ -64,8 +73,12 @@ func NormalizeRole(value string) Role {
 	switch strings.ToLower(strings.TrimSpace(value)) {
 	case string(RoleAdmin):
 		return RoleAdmin
+	case string(RoleTenantAdmin), "tenant-admin", "tenantadmin":
+		return RoleTenantAdmin
 	case string(RoleOperator):
 		return RoleOperator
+	case string(RoleAuditor):
+		return RoleAuditor
 	case "user":
 		// Legacy value from pre-RBAC builds maps to operator by default.
 		return RoleOperator
@@ -76,7 +89,20 @@ func NormalizeRole(value string) Role {
 
 // DefaultRoles returns a deterministic list of built-in roles ordered by privilege.
 func DefaultRoles() []Role {
-	return []Role{RoleAdmin, RoleOperator, RoleViewer}
+	return []Role{RoleAdmin, RoleTenantAdmin, RoleOperator, RoleAuditor, RoleViewer}
+}
+
+// AgentRole maps a server role onto the admin/operator/viewer roles agents
+// understand: tenant admins act as operators and auditors as viewers.
+func AgentRole(role Role) Role {
+	switch NormalizeRole(string(role)) {
+	case RoleAdmin:
+		return RoleAdmin
+	case RoleTenantAdmin, RoleOperator:
+		return RoleOperator
+	default:
+		return RoleViewer
+	}
 }
 
 // SortTenantIDs normalizes tenant slices for stable storage/JSON.
@@ -160,6 +186,16 @@ type Agent struct {
 	LastMetricsSync time.Time `json:"last_metrics_sync,omitempty"` // Last metrics upload
 	TenantID        string    `json:"tenant_id,omitempty"`
 	SiteIDs         []string  `json:"site_ids,omitempty"` // Sites this agent belongs to (can serve multiple sites)
+
+	// Where the agent runs (container, systemd, windows_service, service or
+	// interactive), as detected by the agent. Empty for older agents.
+	RuntimeEnvironment string `json:"runtime_environment,omitempty"`
+
+	// Token lifetime. A zero TokenExpiresAt means the token never expires
+	// (agents registered before rotation existed, or that cannot rotate).
+	TokenIssuedAt          time.Time `json:"token_issued_at,omitempty"`
+	TokenExpiresAt         time.Time `json:"token_expires_at,omitempty"`
+	TokenRotationRequested bool      `json:"token_rotation_requested,omitempty"` // Admin forced rotation pending
 }
 
 // HeartbeatData contains fields that can be sent with an agent heartbeat.
@@ -177,6 +213,8 @@ type HeartbeatData struct {
 	BuildType       string
 	GitCommit       string
 	DeviceCount     int
+
+	RuntimeEnvironment string
 }
 
 // BuildAgentUpdate creates an Agent struct for UpdateAgentInfo if metadata fields are present,
@@ -186,7 +224,7 @@ func (h *HeartbeatData) BuildAgentUpdate(agentID string) *Agent {
 	// Check if any metadata fields are present (beyond just status)
 	hasMetadata := h.Version != "" || h.ProtocolVersion != "" || h.Hostname != "" ||
 		h.IP != "" || h.Platform != "" || h.OSVersion != "" || h.GoVersion != "" ||
-		h.Architecture != "" || h.BuildType != "" || h.GitCommit != ""
+		h.Architecture != "" || h.BuildType != "" || h.GitCommit != "" || h.RuntimeEnvironment != ""
 
 	if !hasMetadata {
 		return nil
@@ -214,6 +252,8 @@ func (h *HeartbeatData) BuildAgentUpdate(agentID string) *Agent {
 		DeviceCount:     h.DeviceCount,
 		LastSeen:        now,
 		LastHeartbeat:   now,
+
+		RuntimeEnvironment: h.RuntimeEnvironment,
 	}
 }
 
@@ -261,21 +301,25 @@ type DeviceWithMetrics struct {
 	MonoPages     int                    `json:"mono_pages,omitempty"`
 	ScanCount     int                    `json:"scan_count,omitempty"`
 	LastMetricsAt *time.Time             `json:"last_metrics_at,omitempty"`
+	StatusState   string                 `json:"status_state,omitempty"` // Canonical state from device status rules
 }
 
 // MetricsSnapshot represents a point-in-time snapshot of device metrics (base struct)
 type MetricsSnapshot struct {
-	ID          int64                  `json:"id"`
-	Serial      string                 `json:"serial"`
-	AgentID     string                 `json:"agent_id,omitempty"`
-	Timestamp   time.Time              `json:"timestamp"`
-	PageCount   int                    `json:"page_count,omitempty"`
-	ColorPages  int                    `json:"color_pages,omitempty"`
-	MonoPages   int                    `json:"mono_pages,omitempty"`
-	ScanCount   int                    `json:"scan_count,omitempty"`
-	FaxPages    int                    `json:"fax_pages,omitempty"`
-	TonerLevels map[string]interface{} `json:"toner_levels,omitempty"`
-	Tier        string                 `json:"tier,omitempty"` // raw, hourly, daily, monthly
+	ID            int64                  `json:"id"`
+	Serial        string                 `json:"serial"`
+	AgentID       string                 `json:"agent_id,omitempty"`
+	Timestamp     time.Time              `json:"timestamp"`
+	PageCount     int                    `json:"page_count,omitempty"`
+	ColorPages    int                    `json:"color_pages,omitempty"`
+	MonoPages     int                    `json:"mono_pages,omitempty"`
+	ScanCount     int                    `json:"scan_count,omitempty"`
+	FaxPages      int                    `json:"fax_pages,omitempty"`
+	DuplexSheets  int                    `json:"duplex_sheets,omitempty"`  // Sheets printed on both sides; 0 when not reported
+	SimplexSheets int                    `json:"simplex_sheets,omitempty"` // Sheets printed on one side
+	NUpSheets     int                    `json:"nup_sheets,omitempty"`     // Sheet sides with more than one page (2-in-1, ...)
+	TonerLevels   map[string]interface{} `json:"toner_levels,omitempty"`
+	Tier          string                 `json:"tier,omitempty"` // raw, hourly, daily, monthly
 }
 
 // MetricSeriesPoint represents a single point in a derived fleet history series.
@@ -388,6 +432,7 @@ type Tenant struct {
 	BillingCode  string    `json:"billing_code,omitempty"`
 	Address      string    `json:"address,omitempty"`
 	LoginDomain  string    `json:"login_domain,omitempty"`
+	Kind         string    `json:"kind,omitempty"` // TenantKindMSPCustomer or TenantKindInternal; selects the default dashboard
 	CreatedAt    time.Time `json:"created_at"`
 }
 
@@ -533,13 +578,29 @@ type UserInvitation struct {
 
 // Session represents a short lived session/token for UI auth
 type Session struct {
-	Token     string    `json:"token"`
-	UserID    int64     `json:"user_id"`
-	ExpiresAt time.Time `json:"expires_at"`
-	CreatedAt time.Time `json:"created_at"`
-	Username  string    `json:"username,omitempty"`
+	Token      string    `json:"token"`
+	UserID     int64     `json:"user_id"`
+	ExpiresAt  time.Time `json:"expires_at"`
+	CreatedAt  time.Time `json:"created_at"`
+	LastSeenAt time.Time `json:"last_seen_at"` // Last authenticated request (created_at until first use)
+	IPAddress  string    `json:"ip_address,omitempty"`
+	UserAgent  string    `json:"user_agent,omitempty"`
+	Username   string    `json:"username,omitempty"`
+	Kind       string    `json:"kind"`           // SessionKindWeb or SessionKindAPI
+	Name       string    `json:"name,omitempty"` // Label of an API token
+	// ExpiryWarnedAt is when the owner of an API token was warned that it
+	// expires soon.
+	ExpiryWarnedAt *time.Time `json:"expiry_warned_at,omitempty"`
 }
 
+// Session kinds. API tokens are long-lived sessions used as Bearer tokens by
+// integrations; they are exempt from the idle timeout and their requests are
+// counted per endpoint.
+const (
+	SessionKindWeb = "web"
+	SessionKindAPI = "api"
+)
+
 // OIDCProvider represents a configured OIDC identity provider
 type OIDCProvider struct {
 	ID           int64     `json:"id"`
@@ -636,6 +697,14 @@ type Store interface {
 	RegisterAgent(ctx context.Context, agent *Agent) error
 	GetAgent(ctx context.Context, agentID string) (*Agent, error)
 	GetAgentByToken(ctx context.Context, token string) (*Agent, error)
+	// RotateAgentToken issues newToken, keeping the old one valid until graceUntil
+	RotateAgentToken(ctx context.Context, agentID, newToken, resendNonce string, expiresAt, graceUntil time.Time) error
+	// GetAgentTokenResendNonce returns the nonce hash that allows resending the current token
+	GetAgentTokenResendNonce(ctx context.Context, agentID string) (string, error)
+	// RequestAgentTokenRotation forces a rotation on the agent's next heartbeat
+	RequestAgentTokenRotation(ctx context.Context, agentID string) error
+	// RequestFleetTokenRotation forces rotation for all agents, optionally limited to tenants
+	RequestFleetTokenRotation(ctx context.Context, tenantIDs []string) (int64, error)
 	ListAgents(ctx context.Context) ([]*Agent, error)
 	ListAgentsPaginated(ctx context.Context, limit, offset int, tenantIDs []string) ([]*Agent, error)
 	CountAgents(ctx context.Context, tenantIDs []string) (int64, error)
@@ -719,6 +788,179 @@ type Store interface {
 	RejectPendingRegistration(ctx context.Context, id int64, reviewedBy, notes string) error
 	DeletePendingAgentRegistration(ctx context.Context, id int64) error
 
+	// Device approval workflow
+	GetDeviceApprovalPolicy(ctx context.Context, tenantID string) (*DeviceApprovalPolicy, error)
+	UpsertDeviceApprovalPolicy(ctx context.Context, policy *DeviceApprovalPolicy) error
+	CreateDeviceApproval(ctx context.Context, approval *DeviceApproval) error
+	GetDeviceApproval(ctx context.Context, serial string) (*DeviceApproval, error)
+	ListDeviceApprovals(ctx context.Context, filter DeviceApprovalFilter) ([]*DeviceApproval, error)
+	SetDeviceApprovalStatus(ctx context.Context, serials []string, status, reviewedBy, notes string) (int64, error)
+	ListUnapprovedDeviceSerials(ctx context.Context) (map[string]struct{}, error)
+
+	// Device status normalization rules (admin-defined)
+	ListDeviceStatusRules(ctx context.Context) ([]devicestatus.Rule, error)
+	ReplaceDeviceStatusRules(ctx context.Context, rules []devicestatus.Rule) error
+
+	// Cartridge yield baseline library
+	ListYieldBaselines(ctx context.Context) ([]YieldBaseline, error)
+	ReplaceYieldBaselines(ctx context.Context, baselines []YieldBaseline) error
+
+	// Scheduled deep scans across agents
+	CreateDeepScanSchedule(ctx context.Context, schedule *DeepScanSchedule) error
+	UpdateDeepScanSchedule(ctx context.Context, schedule *DeepScanSchedule) error
+	GetDeepScanSchedule(ctx context.Context, id int64) (*DeepScanSchedule, error)
+	DeleteDeepScanSchedule(ctx context.Context, id int64) error
+	ListDeepScanSchedules(ctx context.Context) ([]*DeepScanSchedule, error)
+	GetDueDeepScanSchedules(ctx context.Context, before time.Time) ([]*DeepScanSchedule, error)
+	UpdateDeepScanScheduleAfterRun(ctx context.Context, scheduleID, runID int64, nextRun time.Time) error
+	CreateDeepScanRun(ctx context.Context, run *DeepScanRun) error
+	UpdateDeepScanRun(ctx context.Context, run *DeepScanRun) error
+	GetDeepScanRun(ctx context.Context, id int64) (*DeepScanRun, error)
+	ListDeepScanRuns(ctx context.Context, scheduleID int64, limit int) ([]*DeepScanRun, error)
+	SaveDeepScanAgentResult(ctx context.Context, result *DeepScanAgentResult) error
+	ListDeepScanAgentResults(ctx context.Context, runID int64) ([]*DeepScanAgentResult, error)
+	GetPreviousDeepScanAgentResult(ctx context.Context, agentID string, runID int64) (*DeepScanAgentResult, error)
+
+	// Read-only share links to tenant snapshots
+	CreateShareLink(ctx context.Context, link *ShareLink, password string) (string, error)
+	GetShareLink(ctx context.Context, id int64) (*ShareLink, error)
+	GetShareLinkByToken(ctx context.Context, token string) (*ShareLink, error)
+	ListShareLinks(ctx context.Context, tenantIDs []string) ([]*ShareLink, error)
+	RevokeShareLink(ctx context.Context, id int64, revokedBy string) error
+	RecordShareLinkAccess(ctx context.Context, id int64, at time.Time) error
+
+	// Agent settings drift from assigned policy
+	SaveAgentConfigDrift(ctx context.Context, d *AgentConfigDrift) error
+	GetAgentConfigDrift(ctx context.Context, agentID string) (*AgentConfigDrift, error)
+	ListAgentConfigDrift(ctx context.Context, tenantIDs []string, driftedOnly bool) ([]*AgentConfigDrift, error)
+	AddAgentConfigDriftEvent(ctx context.Context, e *AgentConfigDriftEvent) error
+	ListAgentConfigDriftEvents(ctx context.Context, agentID string, limit int) ([]*AgentConfigDriftEvent, error)
+
+	// Device notes and maintenance log
+	AddDeviceNote(ctx context.Context, n *DeviceNote) error
+	GetDeviceNote(ctx context.Context, id int64) (*DeviceNote, error)
+	UpdateDeviceNote(ctx context.Context, n *DeviceNote) error
+	DeleteDeviceNote(ctx context.Context, id int64) error
+	ListDeviceNotes(ctx context.Context, filter DeviceNoteFilter) ([]*DeviceNote, error)
+
+	// Incidents
+	CreateIncident(ctx context.Context, inc *Incident) error
+	GetIncident(ctx context.Context, id int64) (*Incident, error)
+	UpdateIncident(ctx context.Context, inc *Incident) error
+	ListIncidents(ctx context.Context, filter IncidentFilter) ([]*Incident, error)
+	LinkIncidentAlerts(ctx context.Context, incidentID int64, alertIDs []int64) error
+	AddIncidentNote(ctx context.Context, n *IncidentNote) error
+	ListIncidentNotes(ctx context.Context, incidentID int64) ([]*IncidentNote, error)
+
+	// Parse-debug samples for the data quality dashboard
+	SaveParseSample(ctx context.Context, p *ParseSample) error
+	GetParseSample(ctx context.Context, id int64) (*ParseSample, error)
+	ListParseSamples(ctx context.Context, filter ParseSampleFilter) ([]*ParseSample, error)
+	DeleteParseSamplesBefore(ctx context.Context, cutoff time.Time) (int64, error)
+
+	// Device change feed for incremental sync
+	ListDeviceChanges(ctx context.Context, filter DeviceChangeFilter) ([]*DeviceChange, error)
+	GetDeviceChangeBounds(ctx context.Context) (oldest, newest int64, err error)
+	DeleteDeviceChangesBefore(ctx context.Context, cutoff time.Time) (int64, error)
+
+	// Scan-to-email path verification
+	SaveScanPathCheck(ctx context.Context, c *ScanPathCheck) error
+	ListScanPathChecks(ctx context.Context, filter ScanPathCheckFilter) ([]*ScanPathCheck, error)
+	GetLatestScanPathCheck(ctx context.Context, serial string) (*ScanPathCheck, error)
+	DeleteScanPathChecksBefore(ctx context.Context, cutoff time.Time) (int64, error)
+
+	// Vendor cloud webhooks and the readings they carried
+	SetVendorWebhookSecret(ctx context.Context, secret *VendorWebhookSecret) error
+	GetVendorWebhookSecret(ctx context.Context, tenantID, source string) (*VendorWebhookSecret, error)
+	ListVendorWebhookSecrets(ctx context.Context) ([]*VendorWebhookSecret, error)
+	DeleteVendorWebhookSecret(ctx context.Context, tenantID, source string) error
+	SaveVendorWebhookDelivery(ctx context.Context, d *VendorWebhookDelivery, readings []*VendorReading) error
+	GetVendorWebhookDelivery(ctx context.Context, tenantID, source, deliveryID string) (*VendorWebhookDelivery, error)
+	ListVendorWebhookDeliveries(ctx context.Context, filter VendorWebhookDeliveryFilter) ([]*VendorWebhookDelivery, error)
+	ListVendorReadings(ctx context.Context, filter VendorReadingFilter) ([]*VendorReading, error)
+	DeleteVendorWebhookDataBefore(ctx context.Context, cutoff time.Time) (int64, error)
+
+	// Metrics cold storage archives
+	GetOldestMetricsTimestamp(ctx context.Context) (time.Time, error)
+	ListMetricsInRange(ctx context.Context, start, end time.Time) ([]*MetricsSnapshot, error)
+	ReplaceMetricsInRange(ctx context.Context, start, end time.Time, keep []*MetricsSnapshot) (int64, error)
+	InsertMissingMetrics(ctx context.Context, metrics []*MetricsSnapshot) (inserted, skipped int, err error)
+	CreateMetricsArchive(ctx context.Context, a *MetricsArchive) error
+	UpdateMetricsArchive(ctx context.Context, a *MetricsArchive) error
+	GetMetricsArchive(ctx context.Context, id int64) (*MetricsArchive, error)
+	ListMetricsArchives(ctx context.Context) ([]*MetricsArchive, error)
+	DeleteMetricsArchive(ctx context.Context, id int64) error
+
+	// Regional relays
+	CreateRelay(ctx context.Context, relay *Relay) (string, error)
+	GetRelay(ctx context.Context, id int64) (*Relay, error)
+	GetRelayByToken(ctx context.Context, token string) (*Relay, error)
+	ListRelays(ctx context.Context) ([]*Relay, error)
+	UpdateRelayHeartbeat(ctx context.Context, id int64, hb RelayHeartbeat, at time.Time) error
+	DeleteRelay(ctx context.Context, id int64) error
+	SetAgentRelay(ctx context.Context, agentID string, relayID int64, rttMS int) error
+	GetAgentRelayID(ctx context.Context, agentID string) (int64, error)
+
+	// Per-user notification preferences
+	GetUserNotificationPrefs(ctx context.Context, userID int64) (*UserNotificationPrefs, error)
+	UpsertUserNotificationPrefs(ctx context.Context, p *UserNotificationPrefs) error
+	ListEnabledUserNotificationPrefs(ctx context.Context) ([]*UserNotificationPrefs, error)
+	SetUserNotificationProgress(ctx context.Context, userID, lastAlertID int64, digestAt *time.Time) error
+	ListAlertsAfter(ctx context.Context, afterID int64, limit int) ([]*Alert, error)
+
+	// Unknown device telemetry
+	SaveUnknownDeviceReport(ctx context.Context, r *UnknownDeviceReport) error
+	ListUnknownDevices(ctx context.Context, filter UnknownDeviceFilter) ([]*UnknownDevice, error)
+
+	// Vendor detection rules
+	CreateDetectionRule(ctx context.Context, r *DetectionRule) error
+	UpdateDetectionRule(ctx context.Context, r *DetectionRule) error
+	GetDetectionRule(ctx context.Context, id int64) (*DetectionRule, error)
+	ListDetectionRules(ctx context.Context) ([]*DetectionRule, error)
+	DeleteDetectionRule(ctx context.Context, id int64) error
+
+	// Four-eyes approvals for dangerous operations
+	CreateActionApproval(ctx context.Context, a *ActionApproval) error
+	GetActionApproval(ctx context.Context, id int64) (*ActionApproval, error)
+	ListActionApprovals(ctx context.Context, filter ActionApprovalFilter) ([]*ActionApproval, error)
+	DecideActionApproval(ctx context.Context, id int64, status, decidedBy, note string) (bool, error)
+	CompleteActionApproval(ctx context.Context, id int64, status, result string) error
+	ExpireActionApprovals(ctx context.Context, now time.Time) (int64, error)
+
+	// Notification templates (global defaults and per-tenant overrides)
+	UpsertNotificationTemplate(ctx context.Context, t *NotificationTemplate) error
+	GetNotificationTemplate(ctx context.Context, id int64) (*NotificationTemplate, error)
+	ListNotificationTemplates(ctx context.Context) ([]*NotificationTemplate, error)
+	ResolveNotificationTemplate(ctx context.Context, tenantID, channelType string) (*NotificationTemplate, error)
+	DeleteNotificationTemplate(ctx context.Context, id int64) error
+
+	// Tenant onboarding checklist and dashboard layout
+	ListTenantOnboardingSteps(ctx context.Context, tenantID string) ([]*TenantOnboardingStep, error)
+	SetTenantOnboardingStep(ctx context.Context, step *TenantOnboardingStep) error
+	ClearTenantOnboardingStep(ctx context.Context, tenantID, step string) error
+	GetTenantDashboard(ctx context.Context, tenantID string) (*TenantDashboard, error)
+	UpsertTenantDashboard(ctx context.Context, d *TenantDashboard) error
+	DeleteTenantDashboard(ctx context.Context, tenantID string) error
+
+	// Billing periods (finalized per-tenant monthly counters)
+	FinalizeBillingPeriod(ctx context.Context, bp *BillingPeriod) error
+	GetBillingPeriod(ctx context.Context, tenantID, period string) (*BillingPeriod, error)
+	ListBillingPeriods(ctx context.Context, filter BillingPeriodFilter) ([]*BillingPeriod, error)
+
+	// Certified meter reads and reconciliation anomalies
+	AddMeterRead(ctx context.Context, read *MeterRead) error
+	ListMeterReads(ctx context.Context, filter MeterReadFilter) ([]*MeterRead, error)
+	AddAgentSigningKey(ctx context.Context, key *AgentSigningKey) error
+	ListAgentSigningKeys(ctx context.Context, agentID string) ([]*AgentSigningKey, error)
+	RecordMeterReadAnomaly(ctx context.Context, a *MeterReadAnomaly) error
+	ListMeterReadAnomalies(ctx context.Context, filter MeterReadAnomalyFilter) ([]*MeterReadAnomaly, error)
+	ResolveMeterReadAnomaly(ctx context.Context, a *MeterReadAnomaly) error
+
+	// Agent self-update rollout records
+	RecordAgentUpdateRun(ctx context.Context, run *AgentUpdateRun) error
+	GetAgentUpdateRun(ctx context.Context, id int64) (*AgentUpdateRun, error)
+	ListAgentUpdateRuns(ctx context.Context, filter AgentUpdateRunFilter) ([]*AgentUpdateRun, error)
+
 	// User & session management (local login)
 	CreateUser(ctx context.Context, user *User, rawPassword string) error
 	GetUserByUsername(ctx context.Context, username string) (*User, error)
@@ -726,6 +968,8 @@ type Store interface {
 
 	// Sessions
 	CreateSession(ctx context.Context, userID int64, ttlMinutes int) (*Session, error)
+	// CreateSessionForClient creates a session recording the client IP and user agent
+	CreateSessionForClient(ctx context.Context, userID int64, ttlMinutes int, ipAddress, userAgent string) (*Session, error)
 	GetSessionByToken(ctx context.Context, token string) (*Session, error)
 	DeleteSession(ctx context.Context, token string) error
 	// ListSessions returns all sessions (admin) including username when available
@@ -736,6 +980,24 @@ type Store interface {
 	DeleteSessionByHashWithCount(ctx context.Context, tokenHash string) (int64, error)
 	// DeleteExpiredSessions removes all expired sessions and returns the count deleted
 	DeleteExpiredSessions(ctx context.Context) (int64, error)
+	// TouchSession records activity on a session by its stored token hash
+	TouchSession(ctx context.Context, tokenHash string, seenAt time.Time) error
+	// DeleteUserSessions deletes all sessions of a user except exceptHash (may be empty) and returns the count
+	DeleteUserSessions(ctx context.Context, userID int64, exceptHash string) (int64, error)
+	// CreateAPIToken creates a named API token (a session of kind api)
+	CreateAPIToken(ctx context.Context, userID int64, name string, ttlMinutes int) (*Session, error)
+	// ListAPITokensExpiring returns unexpired API tokens that expire before
+	// the given time and whose owner has not been warned yet
+	ListAPITokensExpiring(ctx context.Context, before time.Time) ([]*Session, error)
+	// MarkAPITokenExpiryWarned records that the expiry warning was sent
+	MarkAPITokenExpiryWarned(ctx context.Context, tokenHash string, at time.Time) error
+	// RecordAPITokenUsage adds request counts to the daily usage rows
+	RecordAPITokenUsage(ctx context.Context, usage []APITokenUsage) error
+	// ListAPITokenUsage returns usage rows since the given day, for one token
+	// (by stored hash) or for all tokens when tokenHash is empty
+	ListAPITokenUsage(ctx context.Context, tokenHash string, since time.Time) ([]APITokenUsage, error)
+	// DeleteAPITokenUsageBefore removes usage rows older than the given day
+	DeleteAPITokenUsageBefore(ctx context.Context, before time.Time) (int64, error)
 	GetUserByID(ctx context.Context, id int64) (*User, error)
 	// ListUsers returns all local users (admin UI)
 	ListUsers(ctx context.Context) ([]*User, error)
@@ -781,6 +1043,11 @@ type Store interface {
 	UpsertAgentSettings(ctx context.Context, rec *AgentSettingsRecord) error
 	DeleteAgentSettings(ctx context.Context, agentID string) error
 
+	// Feature flags (server default + per-tenant overrides)
+	ListFeatureFlags(ctx context.Context, tenantID string) ([]*FeatureFlag, error)
+	SetFeatureFlag(ctx context.Context, flag *FeatureFlag) error
+	DeleteFeatureFlag(ctx context.Context, tenantID, key string) error
+
 	// Fleet update policy management
 	GetFleetUpdatePolicy(ctx context.Context, tenantID string) (*FleetUpdatePolicy, error)
 	UpsertFleetUpdatePolicy(ctx context.Context, policy *FleetUpdatePolicy) error
@@ -819,7 +1086,11 @@ type Store interface {
 	UpdateAlertStatus(ctx context.Context, id int64, status AlertStatus) error
 	AcknowledgeAlert(ctx context.Context, id int64, username string) error
 	ResolveAlert(ctx context.Context, id int64) error
+	SuppressAlert(ctx context.Context, id int64, until time.Time) error
+	UnsnoozeExpiredAlerts(ctx context.Context, now time.Time) (int64, error)
 	UpdateAlertNotificationStatus(ctx context.Context, id int64, sent int, lastNotified time.Time) error
+	SetAlertParent(ctx context.Context, parentID int64, childIDs []int64) error
+	ListChildAlerts(ctx context.Context, parentID int64) ([]Alert, error)
 
 	// Alert rule management
 	CreateAlertRule(ctx context.Context, rule *AlertRule) (int64, error)
@@ -917,6 +1188,17 @@ type TenantSettingsRecord struct {
 	UpdatedBy        string                 `json:"updated_by,omitempty"`
 }
 
+// FeatureFlag stores an explicit feature flag value. An empty TenantID holds
+// the server-wide default; tenant rows override it. Flags without a row use
+// the built-in default from the common settings package.
+type FeatureFlag struct {
+	TenantID  string    `json:"tenant_id,omitempty"`
+	Key       string    `json:"key"`
+	Enabled   bool      `json:"enabled"`
+	UpdatedAt time.Time `json:"updated_at"`
+	UpdatedBy string    `json:"updated_by,omitempty"`
+}
+
 // AgentSettingsRecord stores agent-specific override patches (partial payloads).
 // Overrides are applied after tenant settings, subject to global managed sections and tenant enforcement.
 type AgentSettingsRecord struct {
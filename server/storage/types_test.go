@@ -20,6 +20,9 @@ func TestNormalizeRole(t *testing.T) {
 		{"operator uppercase", "OPERATOR", RoleOperator},
 		{"viewer lowercase", "viewer", RoleViewer},
 		{"viewer uppercase", "VIEWER", RoleViewer},
+		{"tenant admin", "tenant_admin", RoleTenantAdmin},
+		{"tenant admin hyphenated", "Tenant-Admin", RoleTenantAdmin},
+		{"auditor", "AUDITOR", RoleAuditor},
 		{"legacy user maps to operator", "user", RoleOperator},
 		{"legacy USER maps to operator", "USER", RoleOperator},
 		{"unknown defaults to viewer", "unknown", RoleViewer},
@@ -41,12 +44,12 @@ func TestDefaultRoles(t *testing.T) {
 	t.Parallel()
 
 	roles := DefaultRoles()
-	if len(roles) != 3 {
-		t.Errorf("DefaultRoles() length = %d, want 3", len(roles))
+	if len(roles) != 5 {
+		t.Errorf("DefaultRoles() length = %d, want 5", len(roles))
 	}
 
-	// Check order: admin, operator, viewer
-	expected := []Role{RoleAdmin, RoleOperator, RoleViewer}
+	// Check order: admin, tenant_admin, operator, auditor, viewer
+	expected := []Role{RoleAdmin, RoleTenantAdmin, RoleOperator, RoleAuditor, RoleViewer}
 	for i, role := range roles {
 		if role != expected[i] {
 			t.Errorf("DefaultRoles()[%d] = %v, want %v", i, role, expected[i])
@@ -54,6 +57,22 @@ func TestDefaultRoles(t *testing.T) {
 	}
 }
 
+func TestAgentRole(t *testing.T) {
+	t.Parallel()
+
+	for role, want := range map[Role]Role{
+		RoleAdmin:       RoleAdmin,
+		RoleTenantAdmin: RoleOperator,
+		RoleOperator:    RoleOperator,
+		RoleAuditor:     RoleViewer,
+		RoleViewer:      RoleViewer,
+	} {
+		if got := AgentRole(role); got != want {
+			t.Errorf("AgentRole(%q) = %q, want %q", role, got, want)
+		}
+	}
+}
+
 func TestSortTenantIDs(t *testing.T) {
 	t.Parallel()
 
@@ -0,0 +1,38 @@
+package storage
+
+import "time"
+
+// UnknownDeviceReport is an anonymized sample of a printer an agent could not
+// attribute to a manufacturer, sent by agents that opted in to unknown-device
+// telemetry. One report is kept per agent and sysObjectID/sysDescr pair.
+type UnknownDeviceReport struct {
+	AgentID     string    `json:"agent_id"`
+	TenantID    string    `json:"tenant_id,omitempty"`
+	SysObjectID string    `json:"sys_object_id"`
+	SysDescr    string    `json:"sys_descr"`
+	Enterprise  string    `json:"enterprise,omitempty"`
+	Model       string    `json:"model,omitempty"`
+	Sightings   int       `json:"sightings"`
+	FirstSeen   time.Time `json:"first_seen"`
+	LastSeen    time.Time `json:"last_seen"`
+}
+
+// UnknownDevice aggregates the reports for one sysObjectID/sysDescr pair
+// across agents.
+type UnknownDevice struct {
+	SysObjectID string    `json:"sys_object_id"`
+	SysDescr    string    `json:"sys_descr"`
+	Enterprise  string    `json:"enterprise,omitempty"`
+	Model       string    `json:"model,omitempty"`
+	Agents      int       `json:"agents"`
+	Sightings   int       `json:"sightings"`
+	FirstSeen   time.Time `json:"first_seen"`
+	LastSeen    time.Time `json:"last_seen"`
+}
+
+// UnknownDeviceFilter narrows ListUnknownDevices. Zero values match everything.
+type UnknownDeviceFilter struct {
+	TenantIDs  []string // Empty = all tenants
+	Enterprise string
+	Limit      int
+}
@@ -11,6 +11,7 @@ type (
 	MaintenanceWindow  = updatepolicy.MaintenanceWindow
 	RolloutControl     = updatepolicy.RolloutControl
 	FleetUpdatePolicy  = updatepolicy.FleetUpdatePolicy
+	FailureDiagnostics = updatepolicy.FailureDiagnostics
 )
 
 const GlobalFleetPolicyTenantID = "__global_auto_update__"
@@ -0,0 +1,93 @@
+package storage
+
+import (
+	"errors"
+	"time"
+)
+
+// Vendor webhook delivery statuses.
+const (
+	VendorDeliveryAccepted = "accepted" // Parsed; its readings were stored
+	VendorDeliveryRejected = "rejected" // Signed but unreadable
+)
+
+// How a vendor reading compares with agent-collected data.
+const (
+	VendorReconcileMatch      = "match"       // Consistent with the agent's latest reading
+	VendorReconcileVendorOnly = "vendor_only" // The agent has no comparable reading
+	VendorReconcileAgentStale = "agent_stale" // Newer than the agent's last reading by more than a day
+	VendorReconcileConflict   = "conflict"    // Contradicts the agent's latest reading
+	VendorReconcileUnmatched  = "unmatched"   // No PrintMaster device has this serial
+)
+
+// ErrVendorWebhookSecretNotFound is returned when a tenant has no secret for a source.
+var ErrVendorWebhookSecretNotFound = errors.New("vendor webhook secret not found")
+
+// VendorWebhookSecret authenticates one tenant's deliveries from one vendor
+// cloud. Secret is only returned when it is generated.
+type VendorWebhookSecret struct {
+	TenantID  string    `json:"tenant_id"`
+	Source    string    `json:"source"`
+	Secret    string    `json:"-"`
+	CreatedBy string    `json:"created_by,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// VendorWebhookDelivery is one webhook received from a vendor cloud platform
+// for a tenant.
+type VendorWebhookDelivery struct {
+	ID         int64     `json:"id"`
+	TenantID   string    `json:"tenant_id"`
+	Source     string    `json:"source"`
+	DeliveryID string    `json:"delivery_id"`
+	Status     string    `json:"status"`
+	Error      string    `json:"error,omitempty"`
+	Events     int       `json:"events"`
+	Matched    int       `json:"matched"`
+	Unmatched  int       `json:"unmatched"`
+	SourceIP   string    `json:"source_ip,omitempty"`
+	ReceivedAt time.Time `json:"received_at"`
+}
+
+// VendorReading is a data point reported by a vendor cloud for a device: an
+// alert, a supply level or a counter. Source marks where it came from, and
+// Reconcile how it compares with the agent's reading at the time
+// (AgentValue/AgentObservedAt, when there was one).
+type VendorReading struct {
+	ID              int64      `json:"id"`
+	DeliveryID      int64      `json:"delivery_id"`
+	Source          string     `json:"source"`
+	Serial          string     `json:"serial"`
+	AgentID         string     `json:"agent_id,omitempty"`
+	TenantID        string     `json:"tenant_id,omitempty"`
+	Kind            string     `json:"kind"`
+	Metric          string     `json:"metric"`
+	Value           int        `json:"value"`
+	Severity        string     `json:"severity,omitempty"`
+	State           string     `json:"state,omitempty"`
+	Message         string     `json:"message,omitempty"`
+	ObservedAt      time.Time  `json:"observed_at"`
+	ReceivedAt      time.Time  `json:"received_at"`
+	Reconcile       string     `json:"reconcile,omitempty"`
+	AgentValue      *int       `json:"agent_value,omitempty"`
+	AgentObservedAt *time.Time `json:"agent_observed_at,omitempty"`
+	AlertID         int64      `json:"alert_id,omitempty"`
+}
+
+// VendorWebhookDeliveryFilter narrows ListVendorWebhookDeliveries.
+type VendorWebhookDeliveryFilter struct {
+	TenantID string
+	Source   string
+	Limit    int
+}
+
+// VendorReadingFilter narrows ListVendorReadings.
+type VendorReadingFilter struct {
+	Serial     string
+	Source     string
+	Kind       string
+	Reconcile  string
+	DeliveryID int64
+	TenantIDs  []string // Empty = all tenants
+	Limit      int
+}
@@ -0,0 +1,127 @@
+package storage
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestVendorWebhookDeliveries(t *testing.T) {
+	t.Parallel()
+	s, err := NewSQLiteStore(":memory:")
+	if err != nil {
+		t.Fatalf("NewSQLiteStore: %v", err)
+	}
+	defer s.Close()
+	ctx := context.Background()
+
+	if d, err := s.GetVendorWebhookDelivery(ctx, "tenant-a", "hp_sds", "d-1"); err != nil || d != nil {
+		t.Fatalf("GetVendorWebhookDelivery on empty store = %+v, %v", d, err)
+	}
+
+	now := time.Now().UTC()
+	agentValue := 1000
+	agentAt := now.Add(-time.Hour)
+	old := &VendorWebhookDelivery{TenantID: "tenant-a", Source: "hp_sds", DeliveryID: "d-0", Status: VendorDeliveryRejected, Error: "invalid JSON",
+		ReceivedAt: now.AddDate(0, 0, -100)}
+	if err := s.SaveVendorWebhookDelivery(ctx, old, nil); err != nil {
+		t.Fatalf("SaveVendorWebhookDelivery(old): %v", err)
+	}
+	d := &VendorWebhookDelivery{TenantID: "tenant-a", Source: "hp_sds", DeliveryID: "d-1", Status: VendorDeliveryAccepted, Events: 2, Matched: 1,
+		Unmatched: 1, SourceIP: "203.0.113.5", ReceivedAt: now}
+	readings := []*VendorReading{
+		{Source: "hp_sds", Serial: "SER1", AgentID: "agent-1", TenantID: "tenant-a", Kind: "counter", Metric: "page_count",
+			Value: 1100, ObservedAt: now, Reconcile: VendorReconcileMatch, AgentValue: &agentValue, AgentObservedAt: &agentAt},
+		{Source: "hp_sds", Serial: "UNKNOWN", Kind: "alert", Metric: "10.00", Severity: "critical", State: "active",
+			Message: "Supply error", ObservedAt: now.Add(-time.Minute), Reconcile: VendorReconcileUnmatched},
+	}
+	if err := s.SaveVendorWebhookDelivery(ctx, d, readings); err != nil {
+		t.Fatalf("SaveVendorWebhookDelivery: %v", err)
+	}
+	if d.ID == 0 || readings[0].ID == 0 || readings[0].DeliveryID != d.ID {
+		t.Fatalf("expected IDs to be set: %+v %+v", d, readings[0])
+	}
+	if err := s.SaveVendorWebhookDelivery(ctx, &VendorWebhookDelivery{TenantID: "tenant-a", Source: "hp_sds", DeliveryID: "d-1", Status: VendorDeliveryAccepted}, nil); err == nil {
+		t.Error("expected duplicate delivery ID to be rejected")
+	}
+	// Delivery IDs are unique per tenant: another tenant's vendor account may reuse one
+	other := &VendorWebhookDelivery{TenantID: "tenant-b", Source: "hp_sds", DeliveryID: "d-1", Status: VendorDeliveryAccepted, ReceivedAt: now}
+	if err := s.SaveVendorWebhookDelivery(ctx, other, nil); err != nil {
+		t.Fatalf("SaveVendorWebhookDelivery(other tenant): %v", err)
+	}
+
+	got, err := s.GetVendorWebhookDelivery(ctx, "tenant-a", "hp_sds", "d-1")
+	if err != nil || got == nil || got.ID != d.ID || got.TenantID != "tenant-a" || got.SourceIP != "203.0.113.5" || got.Unmatched != 1 {
+		t.Fatalf("GetVendorWebhookDelivery = %+v, %v", got, err)
+	}
+	deliveries, err := s.ListVendorWebhookDeliveries(ctx, VendorWebhookDeliveryFilter{TenantID: "tenant-a", Source: "hp_sds"})
+	if err != nil || len(deliveries) != 2 || deliveries[0].ID != d.ID || deliveries[1].Error != "invalid JSON" {
+		t.Fatalf("ListVendorWebhookDeliveries = %+v, %v", deliveries, err)
+	}
+
+	all, err := s.ListVendorReadings(ctx, VendorReadingFilter{})
+	if err != nil || len(all) != 2 {
+		t.Fatalf("ListVendorReadings = %d, %v", len(all), err)
+	}
+	if all[0].AgentValue == nil || *all[0].AgentValue != 1000 || all[0].AgentObservedAt == nil || all[1].AgentValue != nil {
+		t.Errorf("agent values = %+v / %+v", all[0], all[1])
+	}
+	scoped, err := s.ListVendorReadings(ctx, VendorReadingFilter{TenantIDs: []string{"tenant-a"}, Kind: "counter"})
+	if err != nil || len(scoped) != 1 || scoped[0].Serial != "SER1" {
+		t.Errorf("scoped readings = %+v, %v", scoped, err)
+	}
+	unmatched, err := s.ListVendorReadings(ctx, VendorReadingFilter{Reconcile: VendorReconcileUnmatched, DeliveryID: d.ID})
+	if err != nil || len(unmatched) != 1 || unmatched[0].Message != "Supply error" {
+		t.Errorf("unmatched readings = %+v, %v", unmatched, err)
+	}
+
+	removed, err := s.DeleteVendorWebhookDataBefore(ctx, now.AddDate(0, 0, -90))
+	if err != nil || removed != 1 {
+		t.Fatalf("DeleteVendorWebhookDataBefore = %d, %v", removed, err)
+	}
+	if deliveries, _ := s.ListVendorWebhookDeliveries(ctx, VendorWebhookDeliveryFilter{}); len(deliveries) != 2 {
+		t.Errorf("after prune: %d deliveries", len(deliveries))
+	}
+}
+
+func TestVendorWebhookSecrets(t *testing.T) {
+	t.Parallel()
+	s, err := NewSQLiteStore(":memory:")
+	if err != nil {
+		t.Fatalf("NewSQLiteStore: %v", err)
+	}
+	defer s.Close()
+	ctx := context.Background()
+
+	if v, err := s.GetVendorWebhookSecret(ctx, "tenant-a", "hp_sds"); err != nil || v != nil {
+		t.Fatalf("GetVendorWebhookSecret on empty store = %+v, %v", v, err)
+	}
+	for _, v := range []*VendorWebhookSecret{
+		{TenantID: "tenant-a", Source: "hp_sds", Secret: "first", CreatedBy: "admin"},
+		{TenantID: "tenant-b", Source: "hp_sds", Secret: "other"},
+		{TenantID: "tenant-a", Source: "hp_sds", Secret: "rotated", CreatedBy: "admin"},
+	} {
+		if err := s.SetVendorWebhookSecret(ctx, v); err != nil {
+			t.Fatalf("SetVendorWebhookSecret: %v", err)
+		}
+	}
+
+	got, err := s.GetVendorWebhookSecret(ctx, "tenant-a", "hp_sds")
+	if err != nil || got == nil || got.Secret != "rotated" || got.CreatedBy != "admin" {
+		t.Fatalf("GetVendorWebhookSecret = %+v, %v", got, err)
+	}
+	list, err := s.ListVendorWebhookSecrets(ctx)
+	if err != nil || len(list) != 2 || list[0].TenantID != "tenant-a" || list[0].Secret != "" {
+		t.Fatalf("ListVendorWebhookSecrets = %+v, %v", list, err)
+	}
+
+	if err := s.DeleteVendorWebhookSecret(ctx, "tenant-a", "hp_sds"); err != nil {
+		t.Fatalf("DeleteVendorWebhookSecret: %v", err)
+	}
+	if err := s.DeleteVendorWebhookSecret(ctx, "tenant-a", "hp_sds"); err != ErrVendorWebhookSecretNotFound {
+		t.Errorf("second delete err = %v, want ErrVendorWebhookSecretNotFound", err)
+	}
+	if v, _ := s.GetVendorWebhookSecret(ctx, "tenant-b", "hp_sds"); v == nil || v.Secret != "other" {
+		t.Errorf("other tenant's secret = %+v", v)
+	}
+}
@@ -0,0 +1,55 @@
+package storage
+
+import (
+	"context"
+	"testing"
+)
+
+func TestYieldBaselinesReplace(t *testing.T) {
+	t.Parallel()
+	s, err := NewSQLiteStore(":memory:")
+	if err != nil {
+		t.Fatalf("NewSQLiteStore: %v", err)
+	}
+	defer s.Close()
+
+	ctx := context.Background()
+
+	baselines, err := s.ListYieldBaselines(ctx)
+	if err != nil {
+		t.Fatalf("ListYieldBaselines (empty): %v", err)
+	}
+	if len(baselines) != 0 {
+		t.Fatalf("expected no baselines, got %d", len(baselines))
+	}
+
+	want := []YieldBaseline{
+		{PrinterModel: "LaserJet M404", Supply: "black", CartridgeModel: "CF258A", Supplier: "HP", RatedYield: 3000},
+		{PrinterModel: "LaserJet M404", Supply: "black", CartridgeModel: "CF258X", RatedYield: 10000, CartridgeCost: 189.5, Notes: "high yield"},
+	}
+	if err := s.ReplaceYieldBaselines(ctx, want); err != nil {
+		t.Fatalf("ReplaceYieldBaselines: %v", err)
+	}
+	baselines, err = s.ListYieldBaselines(ctx)
+	if err != nil {
+		t.Fatalf("ListYieldBaselines: %v", err)
+	}
+	if len(baselines) != 2 {
+		t.Fatalf("expected 2 baselines, got %d", len(baselines))
+	}
+	for i := range want {
+		got := baselines[i]
+		got.ID = 0
+		if got != want[i] {
+			t.Fatalf("baseline %d = %+v, want %+v", i, got, want[i])
+		}
+	}
+
+	if err := s.ReplaceYieldBaselines(ctx, want[1:]); err != nil {
+		t.Fatalf("ReplaceYieldBaselines (shrink): %v", err)
+	}
+	baselines, _ = s.ListYieldBaselines(ctx)
+	if len(baselines) != 1 || baselines[0].CartridgeModel != "CF258X" {
+		t.Fatalf("unexpected baselines after replace: %+v", baselines)
+	}
+}
@@ -0,0 +1,58 @@
+package storage
+
+import (
+	"fmt"
+	"strings"
+)
+
+// YieldBaseline is one entry of the cartridge baseline library: the rated
+// page yield of a cartridge in a printer model. PrinterModel is matched
+// case-insensitively, first exactly and then by the longest entry contained
+// in the device model. Supply is the toner key reported by agents (black,
+// cyan, ...).
+type YieldBaseline struct {
+	ID             int64  `json:"id,omitempty"`
+	PrinterModel   string `json:"printer_model"`
+	Supply         string `json:"supply"`
+	CartridgeModel string `json:"cartridge_model,omitempty"`
+	Supplier       string `json:"supplier,omitempty"`
+	RatedYield     int    `json:"rated_yield"`
+	// CartridgeCost is the price of one cartridge; 0 when unknown
+	CartridgeCost float64 `json:"cartridge_cost,omitempty"`
+	Notes         string  `json:"notes,omitempty"`
+}
+
+// CostPerPage returns the cartridge cost spread over its rated yield, or 0
+// when no price is known.
+func (b YieldBaseline) CostPerPage() float64 {
+	if b.CartridgeCost <= 0 || b.RatedYield <= 0 {
+		return 0
+	}
+	return b.CartridgeCost / float64(b.RatedYield)
+}
+
+// Normalize trims fields and lower-cases the supply key.
+func (b *YieldBaseline) Normalize() {
+	b.PrinterModel = strings.TrimSpace(b.PrinterModel)
+	b.Supply = strings.ToLower(strings.TrimSpace(b.Supply))
+	b.CartridgeModel = strings.TrimSpace(b.CartridgeModel)
+	b.Supplier = strings.TrimSpace(b.Supplier)
+	b.Notes = strings.TrimSpace(b.Notes)
+}
+
+// Validate reports whether the baseline can be stored.
+func (b *YieldBaseline) Validate() error {
+	if b.PrinterModel == "" {
+		return fmt.Errorf("printer_model is required")
+	}
+	if b.Supply == "" {
+		return fmt.Errorf("supply is required")
+	}
+	if b.RatedYield <= 0 {
+		return fmt.Errorf("rated_yield must be positive")
+	}
+	if b.CartridgeCost < 0 {
+		return fmt.Errorf("cartridge_cost must not be negative")
+	}
+	return nil
+}
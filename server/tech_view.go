@@ -0,0 +1,206 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	authz "printmaster/server/authz"
+	"printmaster/server/storage"
+)
+
+// The technician view (/tech) is a small phone-sized page for someone standing
+// in front of a machine: scan the serial barcode or the agent's QR asset tag,
+// see the device's live status, supplies and open alerts, and trigger remote
+// actions without opening the full dashboard.
+
+var errTechLookupNotFound = errors.New("no device matches this code")
+
+// techLookupLimit caps the number of open alerts returned for a device.
+const techLookupLimit = 5
+
+// techLookupValue extracts the device reference from a scanned code. Agent
+// asset tags encode a URL (/asset?code=SERIAL); serial barcodes and typed
+// input are used as-is.
+func techLookupValue(code string) string {
+	code = strings.TrimSpace(code)
+	if u, err := url.Parse(code); err == nil && u.Scheme != "" && u.Host != "" {
+		q := u.Query()
+		for _, key := range []string{"code", "serial"} {
+			if v := strings.TrimSpace(q.Get(key)); v != "" {
+				return v
+			}
+		}
+		return strings.TrimSpace(u.Path[strings.LastIndex(u.Path, "/")+1:])
+	}
+	return code
+}
+
+// normalizeMAC strips separators so scanned MAC labels match stored values.
+func normalizeMAC(mac string) string {
+	return strings.ToLower(strings.NewReplacer(":", "", "-", "", ".", "").Replace(mac))
+}
+
+// resolveTechLookup finds the device a scanned code refers to: by serial,
+// then asset number, IP or MAC address. allowed reports whether the caller
+// may see devices reported by an agent; hidden devices are never matched.
+func resolveTechLookup(ctx context.Context, code string, allowed func(agentID string) bool) (*storage.Device, error) {
+	value := techLookupValue(code)
+	if value == "" {
+		return nil, errTechLookupNotFound
+	}
+	if device, err := serverStore.GetDevice(ctx, value); err == nil && device != nil && allowed(device.AgentID) {
+		return device, nil
+	}
+
+	devices, err := serverStore.ListAllDevices(ctx)
+	if err != nil {
+		return nil, err
+	}
+	isIP := net.ParseIP(value) != nil
+	mac := normalizeMAC(value)
+	for _, d := range devices {
+		if d == nil || !allowed(d.AgentID) {
+			continue
+		}
+		switch {
+		case strings.EqualFold(d.Serial, value),
+			d.AssetNumber != "" && strings.EqualFold(d.AssetNumber, value),
+			isIP && d.IP == value,
+			len(mac) == 12 && d.MACAddress != "" && normalizeMAC(d.MACAddress) == mac:
+			return d, nil
+		}
+	}
+	return nil, errTechLookupNotFound
+}
+
+// handleTechLookup resolves a scanned code for the technician view.
+// GET /api/v1/tech/lookup?code=...
+func handleTechLookup(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "GET only", http.StatusMethodNotAllowed)
+		return
+	}
+	if !authorizeOrReject(w, r, authz.ActionDevicesRead, authz.ResourceRef{}) {
+		return
+	}
+	principal := getPrincipal(r)
+	if principal == nil {
+		http.Error(w, "unauthenticated", http.StatusUnauthorized)
+		return
+	}
+	scope, ok := tenantScope(principal)
+	if !ok {
+		http.Error(w, "forbidden", http.StatusForbidden)
+		return
+	}
+
+	code := r.URL.Query().Get("code")
+	if strings.TrimSpace(code) == "" {
+		http.Error(w, "code parameter required", http.StatusBadRequest)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	agents, err := serverStore.ListAgents(ctx)
+	if err != nil {
+		logError("Failed to list agents for tech lookup", "error", err)
+		http.Error(w, "lookup failed", http.StatusInternalServerError)
+		return
+	}
+	agentsByID := make(map[string]*storage.Agent, len(agents))
+	for _, a := range agents {
+		if a != nil {
+			agentsByID[a.AgentID] = a
+		}
+	}
+	allowed := func(agentID string) bool {
+		if scope == nil {
+			return true
+		}
+		a := agentsByID[agentID]
+		return a != nil && tenantAllowed(scope, a.TenantID)
+	}
+
+	device, err := resolveTechLookup(ctx, code, allowed)
+	if err != nil {
+		if errors.Is(err, errTechLookupNotFound) {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		logError("Tech lookup failed", "code", code, "error", err)
+		http.Error(w, "lookup failed", http.StatusInternalServerError)
+		return
+	}
+
+	enriched := enrichDevicesWithMetrics(ctx, []*storage.Device{device})[0]
+
+	agentInfo := map[string]interface{}{"id": device.AgentID, "connected": false}
+	if a := agentsByID[device.AgentID]; a != nil {
+		agentInfo["name"] = a.Name
+		agentInfo["last_seen"] = a.LastSeen
+	}
+	connected := device.AgentID != "" && isAgentConnectedWS(device.AgentID)
+	agentInfo["connected"] = connected
+
+	alerts := []*storage.Alert{}
+	if device.AgentID != "" {
+		active, err := serverStore.ListAlerts(ctx, storage.AlertFilters{
+			Status:  string(storage.AlertStatusActive),
+			AgentID: device.AgentID,
+		})
+		if err != nil {
+			logWarn("Failed to list alerts for tech lookup", "serial", device.Serial, "error", err)
+		}
+		for _, a := range active {
+			if a != nil && a.DeviceSerial == device.Serial && len(alerts) < techLookupLimit {
+				alerts = append(alerts, a)
+			}
+		}
+	}
+
+	// Scan-to-email test result, for onboarding; nil until a test arrives
+	scanPath, err := serverStore.GetLatestScanPathCheck(ctx, device.Serial)
+	if err != nil {
+		logWarn("Failed to load scan path check for tech lookup", "serial", device.Serial, "error", err)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"device":    enriched,
+		"status":    deriveDeviceStatus(device),
+		"agent":     agentInfo,
+		"alerts":    alerts,
+		"scan_path": scanPath,
+		"actions": map[string]bool{
+			"collect_metrics": connected,
+			"web_ui":          connected && device.IP != "",
+		},
+	})
+}
+
+// handleTechPage serves the mobile technician view.
+func handleTechPage(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "only GET allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if _, ok := ensureInteractiveSession(w, r); !ok {
+		return
+	}
+	content, err := webFS.ReadFile("web/tech.html")
+	if err != nil {
+		logWarn("Technician page not found", "err", err)
+		http.NotFound(w, r)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Write(content)
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"printmaster/server/storage"
+)
+
+func TestTechLookupValue(t *testing.T) {
+	t.Parallel()
+	cases := map[string]string{
+		"https://agent.local:8443/asset?code=JPBCD12345": "JPBCD12345",
+		"https://server.example/devices/CNB123":          "CNB123",
+		"  AST-0042 ":                                    "AST-0042",
+		"10.0.0.9":                                       "10.0.0.9",
+	}
+	for in, want := range cases {
+		if got := techLookupValue(in); got != want {
+			t.Errorf("techLookupValue(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestHandleTechLookup(t *testing.T) {
+	store := SetupTestStore(t)
+	ctx := context.Background()
+	for _, a := range []*storage.Agent{
+		{AgentID: "agent-a", Name: "Front Office", Hostname: "a", Token: "ta", TenantID: "tenant-a", Status: "active", RegisteredAt: time.Now(), LastSeen: time.Now()},
+		{AgentID: "agent-b", Hostname: "b", Token: "tb", TenantID: "tenant-b", Status: "active", RegisteredAt: time.Now(), LastSeen: time.Now()},
+	} {
+		if err := store.RegisterAgent(ctx, a); err != nil {
+			t.Fatalf("RegisterAgent: %v", err)
+		}
+	}
+	newDevice := func(serial, agentID, ip string) *storage.Device {
+		d := &storage.Device{}
+		d.Serial = serial
+		d.AgentID = agentID
+		d.IP = ip
+		d.LastSeen = time.Now()
+		return d
+	}
+	devA := newDevice("SN-A", "agent-a", "10.0.0.9")
+	devA.MACAddress = "00:11:22:33:44:55"
+	devA.AssetNumber = "AST-0042"
+	devA.StatusMessages = []string{"Paper jam in tray 2"}
+	for _, d := range []*storage.Device{devA, newDevice("SN-B", "agent-b", "10.0.1.9")} {
+		if err := store.UpsertDevice(ctx, d); err != nil {
+			t.Fatalf("UpsertDevice: %v", err)
+		}
+	}
+	if _, err := store.CreateAlert(ctx, &storage.Alert{
+		Type: "supply_low", Severity: "warning", Scope: "device", Status: string(storage.AlertStatusActive),
+		AgentID: "agent-a", DeviceSerial: "SN-A", Title: "Black toner low", TriggeredAt: time.Now(),
+	}); err != nil {
+		t.Fatalf("CreateAlert: %v", err)
+	}
+
+	lookup := func(user *storage.User, code string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/tech/lookup?code="+url.QueryEscape(code), nil)
+		req = InjectTestUser(req, user)
+		rr := httptest.NewRecorder()
+		handleTechLookup(rr, req)
+		return rr
+	}
+
+	viewer := NewTestUser(storage.RoleViewer, "tenant-a")
+	for _, code := range []string{"SN-A", "ast-0042", "10.0.0.9", "0011.2233.4455", "https://agent.local/asset?code=SN-A"} {
+		rr := lookup(viewer, code)
+		if rr.Code != http.StatusOK {
+			t.Fatalf("lookup %q: expected 200, got %d: %s", code, rr.Code, rr.Body.String())
+		}
+		var resp struct {
+			Device struct {
+				Serial string `json:"serial"`
+			} `json:"device"`
+			Status string          `json:"status"`
+			Agent  map[string]any  `json:"agent"`
+			Alerts []storage.Alert `json:"alerts"`
+			Action map[string]bool `json:"actions"`
+		}
+		if err := json.NewDecoder(rr.Body).Decode(&resp); err != nil {
+			t.Fatalf("decode: %v", err)
+		}
+		if resp.Device.Serial != "SN-A" || resp.Status != "jam" || resp.Agent["name"] != "Front Office" {
+			t.Fatalf("lookup %q: unexpected response %+v", code, resp)
+		}
+		if len(resp.Alerts) != 1 || resp.Alerts[0].Title != "Black toner low" {
+			t.Fatalf("lookup %q: expected device alert, got %+v", code, resp.Alerts)
+		}
+		if resp.Action["collect_metrics"] {
+			t.Fatalf("lookup %q: actions should be disabled while the agent is offline", code)
+		}
+	}
+
+	if rr := lookup(viewer, "SN-B"); rr.Code != http.StatusNotFound {
+		t.Fatalf("expected out-of-scope device to be hidden, got %d", rr.Code)
+	}
+	if rr := lookup(NewTestAdminUser(), "SN-B"); rr.Code != http.StatusOK {
+		t.Fatalf("expected admin to see SN-B, got %d", rr.Code)
+	}
+	if rr := lookup(viewer, "UNKNOWN"); rr.Code != http.StatusNotFound {
+		t.Fatalf("expected 404 for unknown code, got %d", rr.Code)
+	}
+}
@@ -29,3 +29,9 @@ func authorizeOrReject(w http.ResponseWriter, r *http.Request, action authz.Acti
 	}
 	return true
 }
+
+// canAccessTenant reports whether the request may perform action on tenantID,
+// without writing a response.
+func canAccessTenant(r *http.Request, action authz.Action, tenantID string) bool {
+	return authorizer != nil && authorizer(r, action, authz.ResourceRef{TenantIDs: []string{tenantID}}) == nil
+}
@@ -10,6 +10,7 @@ import (
 	"io"
 	"net/http"
 	"os"
+	"path/filepath"
 	"strconv"
 	"strings"
 	"sync"
@@ -20,6 +21,7 @@ import (
 	"printmaster/server/storage"
 
 	"printmaster/common/logger"
+	"printmaster/common/meterread"
 )
 
 // getEffectiveScheme determines the protocol scheme, checking X-Forwarded-Proto
@@ -116,8 +118,25 @@ var getUserFromContext func(ctx context.Context) *storage.User
 var (
 	releaseAssetBaseURL   = "https://github.com/mstrhakr/printmaster/releases/download"
 	releaseDownloadClient = &http.Client{Timeout: 2 * time.Minute}
+	// offlineReleases serves agent downloads from the local release cache
+	// instead of GitHub (air-gapped installs).
+	offlineReleases bool
 )
 
+// SetReleaseDownloadClient overrides the HTTP client used to proxy agent
+// downloads from GitHub, e.g. to route them through an outbound proxy.
+func SetReleaseDownloadClient(client *http.Client) {
+	if client != nil {
+		releaseDownloadClient = client
+	}
+}
+
+// SetOfflineReleases toggles serving agent downloads from imported release
+// artifacts rather than GitHub.
+func SetOfflineReleases(enabled bool) {
+	offlineReleases = enabled
+}
+
 // SetAgentEventSink registers a callback invoked for agent lifecycle events.
 func SetAgentEventSink(sink func(eventType string, data map[string]interface{})) {
 	agentEventSink = sink
@@ -191,7 +210,7 @@ func maskTokenValue(token string) string {
 	return token[:4] + "..." + token[len(token)-2:]
 }
 
-func tenantAuditMetadata(name, description, contactName, contactEmail, contactPhone, businessUnit, billingCode, address, loginDomain string) map[string]interface{} {
+func tenantAuditMetadata(name, description, contactName, contactEmail, contactPhone, businessUnit, billingCode, address, loginDomain, kind string) map[string]interface{} {
 	return map[string]interface{}{
 		"name":          name,
 		"description":   description,
@@ -202,6 +221,7 @@ func tenantAuditMetadata(name, description, contactName, contactEmail, contactPh
 		"billing_code":  billingCode,
 		"address":       address,
 		"login_domain":  storage.NormalizeTenantDomain(loginDomain),
+		"kind":          kind,
 	}
 }
 
@@ -241,6 +261,7 @@ type tenantPayload struct {
 	BillingCode  string `json:"billing_code,omitempty"`
 	Address      string `json:"address,omitempty"`
 	LoginDomain  string `json:"login_domain,omitempty"`
+	Kind         string `json:"kind,omitempty"`
 }
 
 type packageRequest struct {
@@ -356,6 +377,14 @@ func handleTenants(w http.ResponseWriter, r *http.Request) {
 				return
 			}
 			logDebug("handleTenants: successfully listed tenants from database", "count", len(list))
+			// Tenant admins and other scoped roles only see their own tenants
+			visible := list[:0]
+			for _, tn := range list {
+				if tn != nil && canAccessTenant(r, authz.ActionTenantsRead, tn.ID) {
+					visible = append(visible, tn)
+				}
+			}
+			list = visible
 			w.Header().Set("Content-Type", "application/json")
 			json.NewEncoder(w).Encode(list)
 			return
@@ -363,6 +392,13 @@ func handleTenants(w http.ResponseWriter, r *http.Request) {
 		logDebug("handleTenants: using in-memory store")
 		list := store.ListTenants()
 		logDebug("handleTenants: listed tenants from memory", "count", len(list))
+		visible := list[:0]
+		for _, tn := range list {
+			if canAccessTenant(r, authz.ActionTenantsRead, tn.ID) {
+				visible = append(visible, tn)
+			}
+		}
+		list = visible
 		w.Header().Set("Content-Type", "application/json")
 		json.NewEncoder(w).Encode(list)
 	case http.MethodPost:
@@ -382,6 +418,12 @@ func handleTenants(w http.ResponseWriter, r *http.Request) {
 			w.Write([]byte(`{"error":"name required"}`))
 			return
 		}
+		kind, ok := storage.NormalizeTenantKind(in.Kind)
+		if !ok {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"error":"invalid kind"}`))
+			return
+		}
 		if dbStore != nil {
 			tn := &storage.Tenant{
 				ID:           in.ID,
@@ -394,6 +436,7 @@ func handleTenants(w http.ResponseWriter, r *http.Request) {
 				BillingCode:  in.BillingCode,
 				Address:      in.Address,
 				LoginDomain:  storage.NormalizeTenantDomain(in.LoginDomain),
+				Kind:         kind,
 			}
 			if tn.ID == "" {
 				// Let storage layer generate ID via SQL default
@@ -419,7 +462,7 @@ func handleTenants(w http.ResponseWriter, r *http.Request) {
 				TargetID:   tn.ID,
 				TenantID:   tn.ID,
 				Details:    fmt.Sprintf("Created tenant %s", tn.Name),
-				Metadata:   tenantAuditMetadata(tn.Name, tn.Description, tn.ContactName, tn.ContactEmail, tn.ContactPhone, tn.BusinessUnit, tn.BillingCode, tn.Address, tn.LoginDomain),
+				Metadata:   tenantAuditMetadata(tn.Name, tn.Description, tn.ContactName, tn.ContactEmail, tn.ContactPhone, tn.BusinessUnit, tn.BillingCode, tn.Address, tn.LoginDomain, tn.Kind),
 			})
 			return
 		}
@@ -434,6 +477,7 @@ func handleTenants(w http.ResponseWriter, r *http.Request) {
 			BillingCode:  in.BillingCode,
 			Address:      in.Address,
 			LoginDomain:  storage.NormalizeTenantDomain(in.LoginDomain),
+			Kind:         kind,
 		})
 		if err != nil {
 			w.WriteHeader(http.StatusInternalServerError)
@@ -448,7 +492,7 @@ func handleTenants(w http.ResponseWriter, r *http.Request) {
 			TargetID:   t.ID,
 			TenantID:   t.ID,
 			Details:    fmt.Sprintf("Created tenant %s", t.Name),
-			Metadata:   tenantAuditMetadata(t.Name, t.Description, t.ContactName, t.ContactEmail, t.ContactPhone, t.BusinessUnit, t.BillingCode, t.Address, t.LoginDomain),
+			Metadata:   tenantAuditMetadata(t.Name, t.Description, t.ContactName, t.ContactEmail, t.ContactPhone, t.BusinessUnit, t.BillingCode, t.Address, t.LoginDomain, t.Kind),
 		})
 	default:
 		w.WriteHeader(http.StatusMethodNotAllowed)
@@ -552,6 +596,12 @@ func handleTenantByID(w http.ResponseWriter, r *http.Request) {
 			w.Write([]byte(`{"error":"name required"}`))
 			return
 		}
+		kind, ok := storage.NormalizeTenantKind(in.Kind)
+		if !ok {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"error":"invalid kind"}`))
+			return
+		}
 		if dbStore != nil {
 			tn, err := dbStore.GetTenant(r.Context(), id)
 			if err != nil {
@@ -569,6 +619,7 @@ func handleTenantByID(w http.ResponseWriter, r *http.Request) {
 			tn.BillingCode = in.BillingCode
 			tn.Address = in.Address
 			tn.LoginDomain = storage.NormalizeTenantDomain(in.LoginDomain)
+			tn.Kind = kind
 			if err := dbStore.UpdateTenant(r.Context(), tn); err != nil {
 				w.WriteHeader(http.StatusInternalServerError)
 				w.Write([]byte(`{"error":"failed to update tenant"}`))
@@ -583,8 +634,8 @@ func handleTenantByID(w http.ResponseWriter, r *http.Request) {
 				TenantID:   tn.ID,
 				Details:    fmt.Sprintf("Updated tenant %s", tn.Name),
 				Metadata: map[string]interface{}{
-					"before": tenantAuditMetadata(before.Name, before.Description, before.ContactName, before.ContactEmail, before.ContactPhone, before.BusinessUnit, before.BillingCode, before.Address, before.LoginDomain),
-					"after":  tenantAuditMetadata(tn.Name, tn.Description, tn.ContactName, tn.ContactEmail, tn.ContactPhone, tn.BusinessUnit, tn.BillingCode, tn.Address, tn.LoginDomain),
+					"before": tenantAuditMetadata(before.Name, before.Description, before.ContactName, before.ContactEmail, before.ContactPhone, before.BusinessUnit, before.BillingCode, before.Address, before.LoginDomain, before.Kind),
+					"after":  tenantAuditMetadata(tn.Name, tn.Description, tn.ContactName, tn.ContactEmail, tn.ContactPhone, tn.BusinessUnit, tn.BillingCode, tn.Address, tn.LoginDomain, tn.Kind),
 				},
 			})
 			return
@@ -606,6 +657,7 @@ func handleTenantByID(w http.ResponseWriter, r *http.Request) {
 			BillingCode:  in.BillingCode,
 			Address:      in.Address,
 			LoginDomain:  storage.NormalizeTenantDomain(in.LoginDomain),
+			Kind:         kind,
 			CreatedAt:    existing.CreatedAt,
 		}
 		res, err := store.UpdateTenant(updated)
@@ -623,8 +675,8 @@ func handleTenantByID(w http.ResponseWriter, r *http.Request) {
 			TenantID:   res.ID,
 			Details:    fmt.Sprintf("Updated tenant %s", res.Name),
 			Metadata: map[string]interface{}{
-				"before": tenantAuditMetadata(existing.Name, existing.Description, existing.ContactName, existing.ContactEmail, existing.ContactPhone, existing.BusinessUnit, existing.BillingCode, existing.Address, existing.LoginDomain),
-				"after":  tenantAuditMetadata(res.Name, res.Description, res.ContactName, res.ContactEmail, res.ContactPhone, res.BusinessUnit, res.BillingCode, res.Address, res.LoginDomain),
+				"before": tenantAuditMetadata(existing.Name, existing.Description, existing.ContactName, existing.ContactEmail, existing.ContactPhone, existing.BusinessUnit, existing.BillingCode, existing.Address, existing.LoginDomain, existing.Kind),
+				"after":  tenantAuditMetadata(res.Name, res.Description, res.ContactName, res.ContactEmail, res.ContactPhone, res.BusinessUnit, res.BillingCode, res.Address, res.LoginDomain, res.Kind),
 			},
 		})
 
@@ -724,6 +776,10 @@ func handleCreateJoinToken(w http.ResponseWriter, r *http.Request) {
 	if !authorizeOrReject(w, r, authz.ActionJoinTokensWrite, resource) {
 		return
 	}
+	// Tokens without a tenant enroll agents globally; keep those to admins
+	if len(resource.TenantIDs) == 0 && !authorizeOrReject(w, r, authz.ActionTenantsWrite, resource) {
+		return
+	}
 	if dbStore != nil {
 		jt, raw, err := dbStore.CreateJoinToken(r.Context(), in.TenantID, in.TTLMinutes, in.OneTime)
 		if err != nil {
@@ -832,11 +888,9 @@ func handleRevokeJoinToken(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(http.StatusMethodNotAllowed)
 		return
 	}
-	if !authorizeOrReject(w, r, authz.ActionJoinTokensWrite, authz.ResourceRef{}) {
-		return
-	}
 	var in struct {
-		ID string `json:"id"`
+		ID       string `json:"id"`
+		TenantID string `json:"tenant_id"`
 	}
 	if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
 		w.WriteHeader(http.StatusBadRequest)
@@ -848,7 +902,37 @@ func handleRevokeJoinToken(w http.ResponseWriter, r *http.Request) {
 		w.Write([]byte(`{"error":"id required"}`))
 		return
 	}
+	// Scoped roles name the token's tenant; revoking without one is admin-only
+	in.TenantID = strings.TrimSpace(in.TenantID)
+	if in.TenantID == "" {
+		if !authorizeOrReject(w, r, authz.ActionJoinTokensWrite, authz.ResourceRef{}) ||
+			!authorizeOrReject(w, r, authz.ActionTenantsWrite, authz.ResourceRef{}) {
+			return
+		}
+	} else if !authorizeOrReject(w, r, authz.ActionJoinTokensWrite, authz.ResourceRef{TenantIDs: []string{in.TenantID}}) {
+		return
+	}
 	if dbStore != nil {
+		if in.TenantID != "" {
+			tokens, err := dbStore.ListJoinTokens(r.Context(), in.TenantID)
+			if err != nil {
+				w.WriteHeader(http.StatusInternalServerError)
+				w.Write([]byte(`{"error":"failed to revoke token"}`))
+				return
+			}
+			found := false
+			for _, jt := range tokens {
+				if jt != nil && jt.ID == in.ID {
+					found = true
+					break
+				}
+			}
+			if !found {
+				w.WriteHeader(http.StatusNotFound)
+				w.Write([]byte(`{"error":"token not found"}`))
+				return
+			}
+		}
 		if err := dbStore.RevokeJoinToken(r.Context(), in.ID); err != nil {
 			w.WriteHeader(http.StatusInternalServerError)
 			w.Write([]byte(`{"error":"failed to revoke token"}`))
@@ -860,6 +944,7 @@ func handleRevokeJoinToken(w http.ResponseWriter, r *http.Request) {
 			Action:     "join_token.revoke",
 			TargetType: "join_token",
 			TargetID:   in.ID,
+			TenantID:   in.TenantID,
 			Details:    "Join token revoked",
 		})
 		return
@@ -867,7 +952,7 @@ func handleRevokeJoinToken(w http.ResponseWriter, r *http.Request) {
 	// fallback: remove from in-memory store
 	store.mu.Lock()
 	defer store.mu.Unlock()
-	if _, ok := store.tokens[in.ID]; ok {
+	if jt, ok := store.tokens[in.ID]; ok && (in.TenantID == "" || jt.TenantID == in.TenantID) {
 		delete(store.tokens, in.ID)
 		w.Header().Set("Content-Type", "application/json")
 		json.NewEncoder(w).Encode(map[string]bool{"success": true})
@@ -875,6 +960,7 @@ func handleRevokeJoinToken(w http.ResponseWriter, r *http.Request) {
 			Action:     "join_token.revoke",
 			TargetType: "join_token",
 			TargetID:   maskTokenValue(in.ID),
+			TenantID:   in.TenantID,
 			Details:    "Join token revoked",
 		})
 		return
@@ -1101,6 +1187,8 @@ func handleRegisterWithToken(w http.ResponseWriter, r *http.Request) {
 		TotalMemoryMB   int64  `json:"total_memory_mb,omitempty"`
 		BuildType       string `json:"build_type,omitempty"`
 		GitCommit       string `json:"git_commit,omitempty"`
+		// Key the agent signs meter reads with (base64 ed25519)
+		MeterReadPublicKey string `json:"meter_read_public_key,omitempty"`
 	}
 	if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
 		if pkgLogger != nil {
@@ -1256,6 +1344,27 @@ func handleRegisterWithToken(w http.ResponseWriter, r *http.Request) {
 			pkgLogger.Info("register-with-token: agent registered successfully", "agent_id", ag.AgentID, "tenant_id", ag.TenantID, "name", ag.Name)
 		}
 
+		// The join token vouches for the key; keys registered earlier are
+		// kept so reads the agent queued before re-joining still verify.
+		signingKeyID := ""
+		if in.MeterReadPublicKey != "" {
+			if pub, err := meterread.ParsePublicKey(in.MeterReadPublicKey); err != nil {
+				if pkgLogger != nil {
+					pkgLogger.Warn("register-with-token: ignoring invalid meter read key", "agent_id", in.AgentID, "error", err)
+				}
+			} else if err := dbStore.AddAgentSigningKey(r.Context(), &storage.AgentSigningKey{
+				AgentID:   in.AgentID,
+				KeyID:     meterread.KeyID(pub),
+				PublicKey: in.MeterReadPublicKey,
+			}); err != nil {
+				if pkgLogger != nil {
+					pkgLogger.Error("register-with-token: failed to store meter read key", "agent_id", in.AgentID, "error", err)
+				}
+			} else {
+				signingKeyID = meterread.KeyID(pub)
+			}
+		}
+
 		emitAgentEvent("agent_registered", ag)
 
 		resp := map[string]interface{}{
@@ -1283,6 +1392,7 @@ func handleRegisterWithToken(w http.ResponseWriter, r *http.Request) {
 				"platform":         strings.TrimSpace(in.Platform),
 				"hostname":         strings.TrimSpace(in.Hostname),
 				"agent_version":    strings.TrimSpace(in.AgentVersion),
+				"signing_key_id":   signingKeyID,
 			},
 		})
 		return
@@ -2602,6 +2712,36 @@ echo "Using direct binary install..."
 install_binary
 `
 
+// serveCachedAgentDownload streams an agent binary from the local release
+// cache populated by bundle imports. Used when the server is offline.
+func serveCachedAgentDownload(w http.ResponseWriter, r *http.Request, version, platform, arch, asset string) {
+	notMirrored := fmt.Sprintf("%s is not in the local release mirror; import a release bundle that contains it", asset)
+	if dbStore == nil {
+		http.Error(w, notMirrored, http.StatusNotFound)
+		return
+	}
+	artifact, err := dbStore.GetReleaseArtifact(r.Context(), "agent", version, platform, arch)
+	if err != nil || artifact == nil || artifact.CachePath == "" || filepath.Base(artifact.CachePath) != asset {
+		http.Error(w, notMirrored, http.StatusNotFound)
+		return
+	}
+	f, err := os.Open(artifact.CachePath)
+	if err != nil {
+		http.Error(w, notMirrored, http.StatusNotFound)
+		return
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		http.Error(w, "failed to read cached artifact", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"%s\"", asset))
+	w.Header().Set("Cache-Control", "no-store")
+	http.ServeContent(w, r, asset, info.ModTime(), f)
+}
+
 func normalizePlatform(input string) string {
 	switch strings.ToLower(strings.TrimSpace(input)) {
 	case "win", "windows", "windows_nt":
@@ -2676,6 +2816,10 @@ func handleAgentDownloadLatest(w http.ResponseWriter, r *http.Request) {
 	}
 
 	asset := fmt.Sprintf("printmaster-agent-%s-%s-%s%s", tag, platform, arch, ext)
+	if offlineReleases {
+		serveCachedAgentDownload(w, r, strings.TrimPrefix(tag, "v"), platform, arch, asset)
+		return
+	}
 	redirectURL := fmt.Sprintf("%s/%s/%s", releaseAssetBaseURL, releaseTag, asset)
 
 	if !proxyDownload {
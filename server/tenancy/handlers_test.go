@@ -2,14 +2,18 @@ package tenancy
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"io"
 	"net/http"
 	"net/http/httptest"
+	"os"
+	"path/filepath"
 	"strings"
 	"testing"
 
 	authz "printmaster/server/authz"
+	"printmaster/server/storage"
 )
 
 func enableTenancyForTest(t *testing.T) {
@@ -233,6 +237,7 @@ func TestHandleTenantUpdate(t *testing.T) {
 	payload := map[string]string{
 		"name":          "Updated Tenant",
 		"contact_phone": "+18005551234",
+		"kind":          "Internal",
 	}
 	body, _ := json.Marshal(payload)
 	req := httptest.NewRequest(http.MethodPut, "/api/v1/tenants/update1", bytes.NewReader(body))
@@ -249,6 +254,18 @@ func TestHandleTenantUpdate(t *testing.T) {
 	if out.ContactPhone != "+18005551234" {
 		t.Fatalf("contact phone not updated")
 	}
+	if out.Kind != storage.TenantKindInternal {
+		t.Fatalf("kind not normalized: %q", out.Kind)
+	}
+
+	payload["kind"] = "reseller"
+	body, _ = json.Marshal(payload)
+	req = httptest.NewRequest(http.MethodPut, "/api/v1/tenants/update1", bytes.NewReader(body))
+	rw = httptest.NewRecorder()
+	handleTenantByID(rw, req)
+	if rw.Code != http.StatusBadRequest {
+		t.Fatalf("invalid kind: expected 400 got %d", rw.Code)
+	}
 }
 
 func TestCreateJoinTokenAndRegister(t *testing.T) {
@@ -469,3 +486,44 @@ func TestHandleAgentDownloadLatestMSI(t *testing.T) {
 		t.Fatalf("expected MSI in redirect, got: %s", loc)
 	}
 }
+
+func TestHandleAgentDownloadLatestOffline(t *testing.T) {
+	enableTenancyForTest(t)
+	origVersion := serverVersion
+	serverVersion = "2.0.0"
+	t.Cleanup(func() { serverVersion = origVersion })
+	SetOfflineReleases(true)
+	t.Cleanup(func() { SetOfflineReleases(false) })
+
+	store, err := storage.NewSQLiteStore(":memory:")
+	if err != nil {
+		t.Fatalf("failed to init store: %v", err)
+	}
+	t.Cleanup(func() { _ = store.Close() })
+	origStore := dbStore
+	dbStore = store
+	t.Cleanup(func() { dbStore = origStore })
+
+	cachePath := filepath.Join(t.TempDir(), "printmaster-agent-v2.0.0-windows-amd64.exe")
+	if err := os.WriteFile(cachePath, []byte("offline-bin"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.UpsertReleaseArtifact(context.Background(), &storage.ReleaseArtifact{
+		Component: "agent", Version: "2.0.0", Platform: "windows", Arch: "amd64",
+		Channel: "stable", SourceURL: "bundle:usb", CachePath: cachePath, SHA256: "x", SizeBytes: 11,
+	}); err != nil {
+		t.Fatalf("UpsertReleaseArtifact: %v", err)
+	}
+
+	rw := httptest.NewRecorder()
+	handleAgentDownloadLatest(rw, httptest.NewRequest(http.MethodGet, "/api/v1/agents/download/latest?platform=windows&arch=amd64", nil))
+	if rw.Code != http.StatusOK || rw.Body.String() != "offline-bin" {
+		t.Fatalf("expected cached binary, got %d %q", rw.Code, rw.Body.String())
+	}
+
+	rw = httptest.NewRecorder()
+	handleAgentDownloadLatest(rw, httptest.NewRequest(http.MethodGet, "/api/v1/agents/download/latest?platform=windows&arch=amd64&format=msi", nil))
+	if rw.Code != http.StatusNotFound {
+		t.Fatalf("expected 404 for format missing from the mirror, got %d", rw.Code)
+	}
+}
@@ -14,6 +14,7 @@ type Tenant struct {
 	BillingCode  string    `json:"billing_code,omitempty"`
 	Address      string    `json:"address,omitempty"`
 	LoginDomain  string    `json:"login_domain,omitempty"`
+	Kind         string    `json:"kind,omitempty"`
 	CreatedAt    time.Time `json:"created_at"`
 }
 
@@ -0,0 +1,494 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"printmaster/server/authz"
+	"printmaster/server/storage"
+	"printmaster/server/tenancy"
+)
+
+// Onboarding checklist steps, in the order new tenants should work through them.
+const (
+	onboardingInstallAgent      = "install_agent"
+	onboardingApproveDevices    = "approve_devices"
+	onboardingConfigureAlerting = "configure_alerting"
+	onboardingInviteUsers       = "invite_users"
+)
+
+var onboardingSteps = []struct {
+	ID, Title, Description string
+}{
+	{onboardingInstallAgent, "Install an agent", "Generate an install package and run it on a machine that can reach the printers."},
+	{onboardingApproveDevices, "Approve devices", "Review the printers the agent discovered and approve the ones to monitor."},
+	{onboardingConfigureAlerting, "Configure alerting", "Add an alert rule or notification channel for this tenant."},
+	{onboardingInviteUsers, "Invite users", "Invite the people who should see this tenant's fleet."},
+}
+
+// onboardingStep is one checklist entry as returned by the API.
+type onboardingStep struct {
+	ID          string    `json:"id"`
+	Title       string    `json:"title"`
+	Description string    `json:"description"`
+	Status      string    `json:"status"` // done, skipped or pending
+	Detail      string    `json:"detail,omitempty"`
+	Manual      bool      `json:"manual"` // Status was set by an operator rather than derived from data
+	UpdatedBy   string    `json:"updated_by,omitempty"`
+	UpdatedAt   time.Time `json:"updated_at,omitzero"`
+}
+
+// onboardingChecklist is the response of GET /api/v1/tenants/{id}/onboarding.
+type onboardingChecklist struct {
+	TenantID  string           `json:"tenant_id"`
+	Kind      string           `json:"kind"`
+	Steps     []onboardingStep `json:"steps"`
+	Completed int              `json:"completed"` // Steps done or skipped
+	Total     int              `json:"total"`
+	Percent   int              `json:"percent"`
+	Finished  bool             `json:"finished"`
+}
+
+// Dashboard widgets the UI knows how to render.
+var dashboardWidgets = []string{
+	"onboarding", "tenants", "sites", "agents", "devices",
+	"critical_supplies", "low_supplies", "pages", "tree",
+}
+
+// defaultDashboardLayouts are the layouts tenants start with. MSP customers
+// lead with fleet size and page volume, which drive billing; internal IT
+// departments lead with supplies, which drive day-to-day work.
+var defaultDashboardLayouts = map[string][]string{
+	storage.TenantKindMSPCustomer: {"onboarding", "sites", "agents", "devices", "pages", "critical_supplies", "low_supplies", "tree"},
+	storage.TenantKindInternal:    {"onboarding", "critical_supplies", "low_supplies", "devices", "agents", "sites", "tree"},
+}
+
+// tenantKind returns the tenant's kind, treating tenants without one as MSP customers.
+func tenantKind(t *storage.Tenant) string {
+	if t == nil || t.Kind == "" {
+		return storage.TenantKindMSPCustomer
+	}
+	return t.Kind
+}
+
+// defaultDashboardLayout returns the layout for a tenant kind.
+func defaultDashboardLayout(kind string) storage.DashboardLayout {
+	widgets, ok := defaultDashboardLayouts[kind]
+	if !ok {
+		widgets = defaultDashboardLayouts[storage.TenantKindMSPCustomer]
+	}
+	return storage.DashboardLayout{Widgets: slices.Clone(widgets)}
+}
+
+func registerTenantOnboardingRoutes() {
+	tenancy.RegisterTenantSubresource("onboarding", handleTenantOnboarding)
+	tenancy.RegisterTenantSubresource("dashboard", handleTenantDashboard)
+}
+
+// loadTenantOrReject fetches a tenant, writing 404/500 when it cannot.
+func loadTenantOrReject(w http.ResponseWriter, r *http.Request, tenantID string) (*storage.Tenant, bool) {
+	tenant, err := serverStore.GetTenant(r.Context(), tenantID)
+	if errors.Is(err, sql.ErrNoRows) {
+		http.Error(w, "tenant not found", http.StatusNotFound)
+		return nil, false
+	}
+	if err != nil {
+		logError("Failed to load tenant", "tenant_id", tenantID, "error", err)
+		http.Error(w, "failed to load tenant", http.StatusInternalServerError)
+		return nil, false
+	}
+	return tenant, true
+}
+
+// handleTenantOnboarding serves /api/v1/tenants/{id}/onboarding.
+// GET returns the checklist; PUT .../{step} marks a step done or skipped and
+// DELETE .../{step} hands it back to automatic detection.
+func handleTenantOnboarding(w http.ResponseWriter, r *http.Request, tenantID, rest string) {
+	resource := authz.ResourceRef{TenantIDs: []string{tenantID}}
+	step := strings.Trim(rest, "/")
+
+	switch {
+	case r.Method == http.MethodGet && step == "":
+		if !authorizeOrReject(w, r, authz.ActionSettingsFleetRead, resource) {
+			return
+		}
+		tenant, ok := loadTenantOrReject(w, r, tenantID)
+		if !ok {
+			return
+		}
+		checklist, err := buildOnboardingChecklist(r.Context(), tenant)
+		if err != nil {
+			logError("Failed to build onboarding checklist", "tenant_id", tenantID, "error", err)
+			http.Error(w, "failed to load onboarding checklist", http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(checklist)
+
+	case (r.Method == http.MethodPut || r.Method == http.MethodDelete) && step != "":
+		if !authorizeOrReject(w, r, authz.ActionSettingsFleetWrite, resource) {
+			return
+		}
+		if !isOnboardingStep(step) {
+			http.Error(w, "unknown onboarding step", http.StatusNotFound)
+			return
+		}
+		tenant, ok := loadTenantOrReject(w, r, tenantID)
+		if !ok {
+			return
+		}
+		ctx := r.Context()
+		var status string
+		if r.Method == http.MethodPut {
+			var req struct {
+				Status string `json:"status"`
+			}
+			if err := decodeJSONBody(r, &req); err != nil {
+				http.Error(w, "invalid JSON", http.StatusBadRequest)
+				return
+			}
+			status = strings.ToLower(strings.TrimSpace(req.Status))
+			if status != storage.OnboardingStepDone && status != storage.OnboardingStepSkipped {
+				http.Error(w, "status must be done or skipped", http.StatusBadRequest)
+				return
+			}
+			rec := &storage.TenantOnboardingStep{TenantID: tenantID, Step: step, Status: status}
+			if principal := getPrincipal(r); principal != nil && principal.User != nil {
+				rec.UpdatedBy = principal.User.Username
+			}
+			if err := serverStore.SetTenantOnboardingStep(ctx, rec); err != nil {
+				logError("Failed to save onboarding step", "tenant_id", tenantID, "step", step, "error", err)
+				http.Error(w, "failed to save onboarding step", http.StatusInternalServerError)
+				return
+			}
+		} else if err := serverStore.ClearTenantOnboardingStep(ctx, tenantID, step); err != nil {
+			logError("Failed to reset onboarding step", "tenant_id", tenantID, "step", step, "error", err)
+			http.Error(w, "failed to reset onboarding step", http.StatusInternalServerError)
+			return
+		}
+
+		details := fmt.Sprintf("Onboarding step %s reset to automatic detection", step)
+		if status != "" {
+			details = fmt.Sprintf("Onboarding step %s marked %s", step, status)
+		}
+		logRequestAudit(r, &storage.AuditEntry{
+			Action:     "tenant.onboarding.update",
+			TargetType: "tenant",
+			TargetID:   tenantID,
+			TenantID:   tenantID,
+			Details:    details,
+			Metadata:   map[string]interface{}{"step": step, "status": status},
+		})
+
+		checklist, err := buildOnboardingChecklist(ctx, tenant)
+		if err != nil {
+			logError("Failed to build onboarding checklist", "tenant_id", tenantID, "error", err)
+			http.Error(w, "failed to load onboarding checklist", http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(checklist)
+
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func isOnboardingStep(id string) bool {
+	for _, s := range onboardingSteps {
+		if s.ID == id {
+			return true
+		}
+	}
+	return false
+}
+
+// buildOnboardingChecklist judges each step from the tenant's data unless an
+// operator marked it done or skipped.
+func buildOnboardingChecklist(ctx context.Context, tenant *storage.Tenant) (*onboardingChecklist, error) {
+	manual, err := serverStore.ListTenantOnboardingSteps(ctx, tenant.ID)
+	if err != nil {
+		return nil, err
+	}
+	overrides := make(map[string]*storage.TenantOnboardingStep, len(manual))
+	for _, m := range manual {
+		overrides[m.Step] = m
+	}
+
+	checklist := &onboardingChecklist{
+		TenantID: tenant.ID,
+		Kind:     tenantKind(tenant),
+		Steps:    make([]onboardingStep, 0, len(onboardingSteps)),
+		Total:    len(onboardingSteps),
+	}
+	for _, def := range onboardingSteps {
+		done, detail, err := detectOnboardingStep(ctx, tenant.ID, def.ID)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", def.ID, err)
+		}
+		step := onboardingStep{
+			ID:          def.ID,
+			Title:       def.Title,
+			Description: def.Description,
+			Status:      "pending",
+			Detail:      detail,
+		}
+		if done {
+			step.Status = storage.OnboardingStepDone
+		}
+		if m, ok := overrides[def.ID]; ok && !done {
+			step.Status = m.Status
+			step.Manual = true
+			step.UpdatedBy = m.UpdatedBy
+			step.UpdatedAt = m.UpdatedAt
+		}
+		if step.Status != "pending" {
+			checklist.Completed++
+		}
+		checklist.Steps = append(checklist.Steps, step)
+	}
+	checklist.Percent = checklist.Completed * 100 / checklist.Total
+	checklist.Finished = checklist.Completed == checklist.Total
+	return checklist, nil
+}
+
+// detectOnboardingStep reports whether the tenant's data shows a step was
+// completed, along with a short progress note for the UI.
+func detectOnboardingStep(ctx context.Context, tenantID, step string) (bool, string, error) {
+	switch step {
+	case onboardingInstallAgent:
+		count, err := serverStore.CountTenantAgents(ctx, tenantID)
+		if err != nil {
+			return false, "", err
+		}
+		return count > 0, fmt.Sprintf("%d agent(s) registered", count), nil
+
+	case onboardingApproveDevices:
+		agents, err := serverStore.ListAgents(ctx)
+		if err != nil {
+			return false, "", err
+		}
+		tenantAgents := make(map[string]bool)
+		for _, a := range agents {
+			if a != nil && a.TenantID == tenantID {
+				tenantAgents[a.AgentID] = true
+			}
+		}
+		devices := 0
+		if len(tenantAgents) > 0 {
+			all, err := serverStore.ListAllDevices(ctx)
+			if err != nil {
+				return false, "", err
+			}
+			for _, d := range all {
+				if d != nil && tenantAgents[d.AgentID] {
+					devices++
+				}
+			}
+		}
+		pending, err := serverStore.ListDeviceApprovals(ctx, storage.DeviceApprovalFilter{
+			Status:    storage.DeviceApprovalPending,
+			TenantIDs: []string{tenantID},
+		})
+		if err != nil {
+			return false, "", err
+		}
+		detail := fmt.Sprintf("%d device(s) monitored", devices)
+		if len(pending) > 0 {
+			detail += fmt.Sprintf(", %d awaiting approval", len(pending))
+		}
+		return devices > 0 && len(pending) == 0, detail, nil
+
+	case onboardingConfigureAlerting:
+		rules, err := serverStore.ListAlertRules(ctx)
+		if err != nil {
+			return false, "", err
+		}
+		channels, err := serverStore.ListNotificationChannels(ctx)
+		if err != nil {
+			return false, "", err
+		}
+		// Only rules and channels scoped to the tenant count; fleet-wide
+		// defaults say nothing about whether anyone set up this customer.
+		ruleCount, channelCount := 0, 0
+		for _, rule := range rules {
+			if rule.Enabled && slices.Contains(rule.TenantIDs, tenantID) {
+				ruleCount++
+			}
+		}
+		for _, ch := range channels {
+			if ch.Enabled && slices.Contains(ch.TenantIDs, tenantID) {
+				channelCount++
+			}
+		}
+		return ruleCount+channelCount > 0, fmt.Sprintf("%d alert rule(s), %d notification channel(s)", ruleCount, channelCount), nil
+
+	case onboardingInviteUsers:
+		users, err := serverStore.ListUsers(ctx)
+		if err != nil {
+			return false, "", err
+		}
+		members := 0
+		for _, u := range users {
+			if u != nil && (u.TenantID == tenantID || slices.Contains(u.TenantIDs, tenantID)) {
+				members++
+			}
+		}
+		invites, err := serverStore.ListUserInvitations(ctx)
+		if err != nil {
+			return false, "", err
+		}
+		open := 0
+		now := time.Now()
+		for _, inv := range invites {
+			if inv != nil && inv.TenantID == tenantID && !inv.Used && inv.ExpiresAt.After(now) {
+				open++
+			}
+		}
+		detail := fmt.Sprintf("%d user(s)", members)
+		if open > 0 {
+			detail += fmt.Sprintf(", %d open invitation(s)", open)
+		}
+		return members+open > 0, detail, nil
+	}
+	return false, "", fmt.Errorf("unknown onboarding step %q", step)
+}
+
+// tenantDashboardView is the response of GET /api/v1/tenants/{id}/dashboard.
+type tenantDashboardView struct {
+	TenantID         string                  `json:"tenant_id"`
+	Kind             string                  `json:"kind"`
+	Layout           storage.DashboardLayout `json:"layout"`
+	Customized       bool                    `json:"customized"`
+	UpdatedBy        string                  `json:"updated_by,omitempty"`
+	UpdatedAt        time.Time               `json:"updated_at,omitzero"`
+	AvailableWidgets []string                `json:"available_widgets"`
+}
+
+// handleTenantDashboard serves /api/v1/tenants/{id}/dashboard. GET returns the
+// tenant's layout (the default for its kind until customized), PUT replaces
+// it and DELETE restores the default.
+func handleTenantDashboard(w http.ResponseWriter, r *http.Request, tenantID, rest string) {
+	if strings.Trim(rest, "/") != "" {
+		http.NotFound(w, r)
+		return
+	}
+	resource := authz.ResourceRef{TenantIDs: []string{tenantID}}
+	ctx := r.Context()
+
+	switch r.Method {
+	case http.MethodGet:
+		if !authorizeOrReject(w, r, authz.ActionSettingsFleetRead, resource) {
+			return
+		}
+	case http.MethodPut, http.MethodDelete:
+		if !authorizeOrReject(w, r, authz.ActionSettingsFleetWrite, resource) {
+			return
+		}
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	tenant, ok := loadTenantOrReject(w, r, tenantID)
+	if !ok {
+		return
+	}
+
+	switch r.Method {
+	case http.MethodPut:
+		var layout storage.DashboardLayout
+		if err := decodeJSONBody(r, &layout); err != nil {
+			http.Error(w, "invalid JSON", http.StatusBadRequest)
+			return
+		}
+		widgets, err := normalizeDashboardWidgets(layout.Widgets)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		d := &storage.TenantDashboard{TenantID: tenantID, Layout: storage.DashboardLayout{Widgets: widgets}}
+		if principal := getPrincipal(r); principal != nil && principal.User != nil {
+			d.UpdatedBy = principal.User.Username
+		}
+		if err := serverStore.UpsertTenantDashboard(ctx, d); err != nil {
+			logError("Failed to save tenant dashboard", "tenant_id", tenantID, "error", err)
+			http.Error(w, "failed to save dashboard", http.StatusInternalServerError)
+			return
+		}
+		logRequestAudit(r, &storage.AuditEntry{
+			Action:     "tenant.dashboard.update",
+			TargetType: "tenant",
+			TargetID:   tenantID,
+			TenantID:   tenantID,
+			Details:    "Customized tenant dashboard layout",
+			Metadata:   map[string]interface{}{"widgets": widgets},
+		})
+	case http.MethodDelete:
+		if err := serverStore.DeleteTenantDashboard(ctx, tenantID); err != nil {
+			logError("Failed to reset tenant dashboard", "tenant_id", tenantID, "error", err)
+			http.Error(w, "failed to reset dashboard", http.StatusInternalServerError)
+			return
+		}
+		logRequestAudit(r, &storage.AuditEntry{
+			Action:     "tenant.dashboard.reset",
+			TargetType: "tenant",
+			TargetID:   tenantID,
+			TenantID:   tenantID,
+			Details:    "Reset tenant dashboard to the default layout",
+		})
+	}
+
+	view, err := tenantDashboard(ctx, tenant)
+	if err != nil {
+		logError("Failed to load tenant dashboard", "tenant_id", tenantID, "error", err)
+		http.Error(w, "failed to load dashboard", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(view)
+}
+
+// tenantDashboard returns the tenant's stored layout or the default for its kind.
+func tenantDashboard(ctx context.Context, tenant *storage.Tenant) (*tenantDashboardView, error) {
+	view := &tenantDashboardView{
+		TenantID:         tenant.ID,
+		Kind:             tenantKind(tenant),
+		AvailableWidgets: dashboardWidgets,
+	}
+	stored, err := serverStore.GetTenantDashboard(ctx, tenant.ID)
+	if err != nil {
+		return nil, err
+	}
+	if stored == nil {
+		view.Layout = defaultDashboardLayout(view.Kind)
+		return view, nil
+	}
+	view.Layout = stored.Layout
+	view.Customized = true
+	view.UpdatedBy = stored.UpdatedBy
+	view.UpdatedAt = stored.UpdatedAt
+	return view, nil
+}
+
+// normalizeDashboardWidgets validates widget IDs and drops duplicates while
+// keeping the requested order.
+func normalizeDashboardWidgets(widgets []string) ([]string, error) {
+	out := make([]string, 0, len(widgets))
+	for _, id := range widgets {
+		id = strings.ToLower(strings.TrimSpace(id))
+		if !slices.Contains(dashboardWidgets, id) {
+			return nil, fmt.Errorf("unknown widget %q", id)
+		}
+		if !slices.Contains(out, id) {
+			out = append(out, id)
+		}
+	}
+	return out, nil
+}
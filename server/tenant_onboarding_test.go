@@ -0,0 +1,157 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"printmaster/server/storage"
+)
+
+func TestTenantOnboardingChecklist(t *testing.T) {
+	store := SetupTestStore(t)
+	ctx := context.Background()
+	if err := store.CreateTenant(ctx, &storage.Tenant{ID: "tenant-a", Name: "Acme"}); err != nil {
+		t.Fatalf("CreateTenant: %v", err)
+	}
+
+	call := func(user *storage.User, method, path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, "/api/v1/tenants/tenant-a/onboarding"+path, strings.NewReader(body))
+		rr := httptest.NewRecorder()
+		handleTenantOnboarding(rr, InjectTestUser(req, user), "tenant-a", strings.TrimPrefix(path, "/"))
+		return rr
+	}
+	checklist := func(rr *httptest.ResponseRecorder) onboardingChecklist {
+		t.Helper()
+		var c onboardingChecklist
+		if rr.Code != http.StatusOK {
+			t.Fatalf("expected 200, got %d %s", rr.Code, rr.Body.String())
+		}
+		if err := json.Unmarshal(rr.Body.Bytes(), &c); err != nil {
+			t.Fatalf("decode: %v", err)
+		}
+		return c
+	}
+	status := func(c onboardingChecklist, id string) string {
+		for _, s := range c.Steps {
+			if s.ID == id {
+				return s.Status
+			}
+		}
+		return ""
+	}
+	operator := NewTestUser(storage.RoleOperator, "tenant-a")
+	viewer := NewTestUser(storage.RoleViewer, "tenant-a")
+
+	c := checklist(call(viewer, http.MethodGet, "", ""))
+	if c.Completed != 0 || c.Total != 4 || c.Kind != storage.TenantKindMSPCustomer {
+		t.Fatalf("new tenant checklist = %+v", c)
+	}
+
+	// Agent with an approved device and a pending one
+	if err := store.RegisterAgent(ctx, &storage.Agent{AgentID: "agent-a", Hostname: "a", Token: "ta", TenantID: "tenant-a",
+		Status: "active", RegisteredAt: time.Now(), LastSeen: time.Now()}); err != nil {
+		t.Fatalf("RegisterAgent: %v", err)
+	}
+	dev := &storage.Device{}
+	dev.Serial, dev.AgentID, dev.LastSeen = "SN-A", "agent-a", time.Now()
+	if err := store.UpsertDevice(ctx, dev); err != nil {
+		t.Fatalf("UpsertDevice: %v", err)
+	}
+	if err := store.CreateDeviceApproval(ctx, &storage.DeviceApproval{Serial: "SN-B", TenantID: "tenant-a", AgentID: "agent-a"}); err != nil {
+		t.Fatalf("CreateDeviceApproval: %v", err)
+	}
+	c = checklist(call(viewer, http.MethodGet, "", ""))
+	if status(c, onboardingInstallAgent) != "done" || status(c, onboardingApproveDevices) != "pending" {
+		t.Fatalf("after agent install: %+v", c.Steps)
+	}
+
+	if _, err := store.SetDeviceApprovalStatus(ctx, []string{"SN-B"}, storage.DeviceApprovalApproved, "admin", ""); err != nil {
+		t.Fatalf("SetDeviceApprovalStatus: %v", err)
+	}
+	if _, err := store.CreateAlertRule(ctx, &storage.AlertRule{Name: "Toner", Enabled: true, Type: "supply_low",
+		Severity: "warning", Scope: "tenant", TenantIDs: []string{"tenant-a"}}); err != nil {
+		t.Fatalf("CreateAlertRule: %v", err)
+	}
+	c = checklist(call(viewer, http.MethodGet, "", ""))
+	if status(c, onboardingApproveDevices) != "done" || status(c, onboardingConfigureAlerting) != "done" || c.Completed != 3 {
+		t.Fatalf("after approvals and alerting: %+v", c)
+	}
+
+	if rr := call(viewer, http.MethodPut, "/invite_users", `{"status":"skipped"}`); rr.Code != http.StatusForbidden {
+		t.Fatalf("viewer skip: expected 403, got %d", rr.Code)
+	}
+	if rr := call(operator, http.MethodPut, "/invite_users", `{"status":"later"}`); rr.Code != http.StatusBadRequest {
+		t.Fatalf("invalid status: expected 400, got %d", rr.Code)
+	}
+	if rr := call(operator, http.MethodPut, "/unknown", `{"status":"done"}`); rr.Code != http.StatusNotFound {
+		t.Fatalf("unknown step: expected 404, got %d", rr.Code)
+	}
+	c = checklist(call(operator, http.MethodPut, "/invite_users", `{"status":"skipped"}`))
+	if status(c, onboardingInviteUsers) != "skipped" || !c.Finished || c.Percent != 100 {
+		t.Fatalf("after skip: %+v", c)
+	}
+
+	c = checklist(call(operator, http.MethodDelete, "/invite_users", ""))
+	if status(c, onboardingInviteUsers) != "pending" || c.Finished {
+		t.Fatalf("after reset: %+v", c)
+	}
+
+	other := NewTestUser(storage.RoleOperator, "tenant-b")
+	if rr := call(other, http.MethodGet, "", ""); rr.Code != http.StatusForbidden {
+		t.Fatalf("other tenant: expected 403, got %d", rr.Code)
+	}
+}
+
+func TestTenantDashboardLayouts(t *testing.T) {
+	store := SetupTestStore(t)
+	ctx := context.Background()
+	if err := store.CreateTenant(ctx, &storage.Tenant{ID: "it", Name: "IT", Kind: storage.TenantKindInternal}); err != nil {
+		t.Fatalf("CreateTenant: %v", err)
+	}
+
+	call := func(user *storage.User, method, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, "/api/v1/tenants/it/dashboard", strings.NewReader(body))
+		rr := httptest.NewRecorder()
+		handleTenantDashboard(rr, InjectTestUser(req, user), "it", "")
+		return rr
+	}
+	view := func(rr *httptest.ResponseRecorder) tenantDashboardView {
+		t.Helper()
+		var v tenantDashboardView
+		if rr.Code != http.StatusOK {
+			t.Fatalf("expected 200, got %d %s", rr.Code, rr.Body.String())
+		}
+		if err := json.Unmarshal(rr.Body.Bytes(), &v); err != nil {
+			t.Fatalf("decode: %v", err)
+		}
+		return v
+	}
+	operator := NewTestUser(storage.RoleOperator, "it")
+	viewer := NewTestUser(storage.RoleViewer, "it")
+
+	v := view(call(viewer, http.MethodGet, ""))
+	if v.Customized || v.Kind != storage.TenantKindInternal || v.Layout.Widgets[1] != "critical_supplies" {
+		t.Fatalf("default internal layout = %+v", v)
+	}
+
+	if rr := call(viewer, http.MethodPut, `{"widgets":["tree"]}`); rr.Code != http.StatusForbidden {
+		t.Fatalf("viewer update: expected 403, got %d", rr.Code)
+	}
+	if rr := call(operator, http.MethodPut, `{"widgets":["tree","weather"]}`); rr.Code != http.StatusBadRequest {
+		t.Fatalf("unknown widget: expected 400, got %d", rr.Code)
+	}
+	v = view(call(operator, http.MethodPut, `{"widgets":["devices"," Tree","devices"]}`))
+	if !v.Customized || strings.Join(v.Layout.Widgets, ",") != "devices,tree" || v.UpdatedBy != operator.Username {
+		t.Fatalf("customized layout = %+v", v)
+	}
+
+	v = view(call(operator, http.MethodDelete, ""))
+	if v.Customized || len(v.Layout.Widgets) != len(defaultDashboardLayouts[storage.TenantKindInternal]) {
+		t.Fatalf("reset layout = %+v", v)
+	}
+}
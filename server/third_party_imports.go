@@ -0,0 +1,136 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	authz "printmaster/server/authz"
+	"printmaster/server/imports"
+	"printmaster/server/storage"
+)
+
+// maxImportBodySize bounds third-party export uploads; print logs covering
+// years of jobs are far larger than regular API requests.
+const maxImportBodySize = 64 << 20 // 64MB
+
+type thirdPartyImportRequest struct {
+	CSV        string            `json:"csv"`
+	DryRun     bool              `json:"dry_run"`
+	DateLayout string            `json:"date_layout"`
+	Timezone   string            `json:"timezone"`
+	Serials    map[string]string `json:"serials"`
+}
+
+// handleThirdPartyImport imports device counters exported from PaperCut or
+// SafeQ as metrics history (POST /api/v1/imports/{papercut|safeq}). The body
+// is either the raw CSV (options in the query string) or a JSON envelope
+// that can also map printer names to serials.
+func handleThirdPartyImport(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "POST only", http.StatusMethodNotAllowed)
+		return
+	}
+	if !authorizeOrReject(w, r, authz.ActionImportsWrite, authz.ResourceRef{}) {
+		return
+	}
+	source := strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/v1/imports/"), "/")
+
+	var req thirdPartyImportRequest
+	var body io.Reader
+	r.Body = http.MaxBytesReader(w, r.Body, maxImportBodySize)
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "application/json") {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "invalid JSON", http.StatusBadRequest)
+			return
+		}
+		body = strings.NewReader(req.CSV)
+	} else {
+		q := r.URL.Query()
+		req.DryRun, _ = strconv.ParseBool(q.Get("dry_run"))
+		req.DateLayout = q.Get("date_layout")
+		req.Timezone = q.Get("timezone")
+		body = r.Body
+	}
+
+	opts := imports.Options{DateLayout: strings.TrimSpace(req.DateLayout), Serials: req.Serials}
+	if tz := strings.TrimSpace(req.Timezone); tz != "" {
+		loc, err := time.LoadLocation(tz)
+		if err != nil {
+			http.Error(w, "unknown timezone", http.StatusBadRequest)
+			return
+		}
+		opts.Location = loc
+	}
+
+	parsed, err := imports.Parse(source, body, opts)
+	if err != nil {
+		var maxErr *http.MaxBytesError
+		switch {
+		case errors.Is(err, imports.ErrUnknownSource):
+			http.Error(w, "unknown import source (papercut or safeq)", http.StatusNotFound)
+		case errors.As(err, &maxErr):
+			http.Error(w, "export too large", http.StatusRequestEntityTooLarge)
+		default:
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		}
+		return
+	}
+
+	ctx := r.Context()
+	scope, _ := tenantScope(getPrincipal(r))
+	agentTenants := make(map[string]string)
+	allow := func(device *storage.Device) bool {
+		if scope == nil {
+			return true
+		}
+		tenantID, ok := agentTenants[device.AgentID]
+		if !ok {
+			if agent, err := serverStore.GetAgent(ctx, device.AgentID); err == nil && agent != nil {
+				tenantID = agent.TenantID
+			}
+			agentTenants[device.AgentID] = tenantID
+		}
+		return tenantAllowed(scope, tenantID)
+	}
+
+	summary, err := imports.Import(ctx, serverStore, parsed, req.DryRun, allow)
+	if err != nil {
+		logError("Third-party import failed", "source", parsed.Source, "error", err)
+		http.Error(w, "import failed", http.StatusInternalServerError)
+		return
+	}
+
+	if !summary.DryRun {
+		logInfo("Imported third-party history", "source", summary.Source, "devices", len(summary.Devices), "points", summary.Imported, "unmatched", len(summary.Unmatched))
+		actorType, actorID, actorName, actorTenant := auditActorFromPrincipal(r)
+		logAuditEntry(ctx, &storage.AuditEntry{
+			ActorType:  actorType,
+			ActorID:    actorID,
+			ActorName:  actorName,
+			TenantID:   actorTenant,
+			Action:     "import_third_party_history",
+			TargetType: "import",
+			TargetID:   summary.Source,
+			Details:    fmt.Sprintf("Imported %d history points for %d devices from %s", summary.Imported, len(summary.Devices), summary.Source),
+			Metadata: map[string]interface{}{
+				"source":    summary.Source,
+				"rows":      summary.Rows,
+				"devices":   len(summary.Devices),
+				"points":    summary.Imported,
+				"unmatched": len(summary.Unmatched),
+				"skipped":   len(summary.Skipped),
+			},
+			IPAddress: extractClientIP(r),
+			UserAgent: r.Header.Get("User-Agent"),
+		})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(summary)
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"printmaster/server/imports"
+	"printmaster/server/storage"
+)
+
+func TestThirdPartyImportSafeQ(t *testing.T) {
+	store := SetupTestStore(t)
+	ctx := context.Background()
+	if err := store.RegisterAgent(ctx, &storage.Agent{AgentID: "agent-a", Hostname: "a", Token: "ta", Status: "active", RegisteredAt: time.Now(), LastSeen: time.Now()}); err != nil {
+		t.Fatalf("RegisterAgent: %v", err)
+	}
+	dev := &storage.Device{}
+	dev.Serial, dev.AgentID, dev.IP, dev.LastSeen = "SN-A1", "agent-a", "10.0.0.1", time.Now()
+	if err := store.UpsertDevice(ctx, dev); err != nil {
+		t.Fatalf("UpsertDevice: %v", err)
+	}
+
+	export := "Serial number,Device name,Reading date,Total,B&W,Color\n" +
+		"SN-A1,Floor 2,2024-01-01,1000,800,200\n" +
+		"SN-A1,Floor 2,2024-02-01,1500,1200,300\n" +
+		"SN-ZZ,Old printer,2024-02-01,10,10,0\n"
+	post := func(user *storage.User, query string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/imports/safeq"+query, strings.NewReader(export))
+		req.Header.Set("Content-Type", "text/csv")
+		rr := httptest.NewRecorder()
+		handleThirdPartyImport(rr, InjectTestUser(req, user))
+		return rr
+	}
+
+	if rr := post(NewTestUser(storage.RoleOperator), ""); rr.Code != http.StatusForbidden {
+		t.Fatalf("operator import: expected 403, got %d", rr.Code)
+	}
+
+	admin := NewTestUser(storage.RoleAdmin)
+	rr := post(admin, "?dry_run=true")
+	var summary imports.Summary
+	if err := json.Unmarshal(rr.Body.Bytes(), &summary); err != nil || rr.Code != http.StatusOK {
+		t.Fatalf("dry run: %d %s", rr.Code, rr.Body.String())
+	}
+	if !summary.DryRun || len(summary.Devices) != 1 || summary.Devices[0].Points != 2 || len(summary.Unmatched) != 1 {
+		t.Fatalf("unexpected dry run summary: %+v", summary)
+	}
+	if _, _, n, _ := store.GetMetricsBounds(ctx, "SN-A1"); n != 0 {
+		t.Fatalf("dry run stored %d points", n)
+	}
+
+	rr = post(admin, "")
+	if rr.Code != http.StatusOK {
+		t.Fatalf("import: %d %s", rr.Code, rr.Body.String())
+	}
+	history, err := store.GetMetricsHistory(ctx, "SN-A1", time.Time{})
+	if err != nil || len(history) != 2 {
+		t.Fatalf("expected 2 imported points, got %d (%v)", len(history), err)
+	}
+	latest, _ := store.GetLatestMetrics(ctx, "SN-A1")
+	if latest == nil || latest.PageCount != 1500 || latest.ColorPages != 300 {
+		t.Fatalf("unexpected latest snapshot: %+v", latest)
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/imports/printlogger", strings.NewReader(export))
+	rr = httptest.NewRecorder()
+	handleThirdPartyImport(rr, InjectTestUser(req, admin))
+	if rr.Code != http.StatusNotFound {
+		t.Fatalf("unknown source: expected 404, got %d", rr.Code)
+	}
+}
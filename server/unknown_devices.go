@@ -0,0 +1,125 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	authz "printmaster/server/authz"
+	"printmaster/server/storage"
+)
+
+// maxUnknownDevicesPerBatch caps the reports accepted in one upload.
+const maxUnknownDevicesPerBatch = 500
+
+// unknownDeviceUpload is one entry in an agent's unknown-devices batch.
+type unknownDeviceUpload struct {
+	SysObjectID string    `json:"sys_object_id"`
+	SysDescr    string    `json:"sys_descr"`
+	Enterprise  string    `json:"enterprise"`
+	Model       string    `json:"model"`
+	Count       int       `json:"count"`
+	FirstSeen   time.Time `json:"first_seen"`
+	LastSeen    time.Time `json:"last_seen"`
+}
+
+// handleUnknownDevicesBatch stores anonymized samples of printers an agent
+// could not attribute to a manufacturer. Agents only send them when unknown
+// device telemetry is enabled for their tenant.
+func handleUnknownDevicesBatch(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "POST only", http.StatusMethodNotAllowed)
+		return
+	}
+	var req struct {
+		AgentID string                `json:"agent_id"`
+		Devices []unknownDeviceUpload `json:"devices"`
+	}
+	if err := decodeJSONBody(r, &req); err != nil {
+		logWarn("Invalid JSON in unknown devices batch", "error", err)
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+	if len(req.Devices) > maxUnknownDevicesPerBatch {
+		req.Devices = req.Devices[:maxUnknownDevicesPerBatch]
+	}
+
+	agent := r.Context().Value(agentContextKey).(*storage.Agent)
+	ctx := r.Context()
+	stored := 0
+	for _, d := range req.Devices {
+		report := &storage.UnknownDeviceReport{
+			AgentID:     agent.AgentID,
+			TenantID:    agent.TenantID,
+			SysObjectID: strings.Trim(strings.TrimSpace(d.SysObjectID), "."),
+			SysDescr:    capLength(strings.TrimSpace(d.SysDescr), 255),
+			Enterprise:  strings.TrimSpace(d.Enterprise),
+			Model:       capLength(strings.TrimSpace(d.Model), 128),
+			Sightings:   d.Count,
+			FirstSeen:   d.FirstSeen,
+			LastSeen:    d.LastSeen,
+		}
+		if report.SysObjectID == "" && report.SysDescr == "" {
+			continue
+		}
+		if err := serverStore.SaveUnknownDeviceReport(ctx, report); err != nil {
+			logError("Failed to store unknown device report", "agent_id", agent.AgentID, "error", err)
+			continue
+		}
+		stored++
+	}
+
+	logDebug("Unknown devices batch received", "agent_id", agent.AgentID, "received", len(req.Devices), "stored", stored)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"stored": stored})
+}
+
+// handleUnknownDevices lists unrecognized printers reported by agents,
+// grouped by sysObjectID and sysDescr:
+//
+//	GET /api/v1/unknown-devices?enterprise=&limit=
+func handleUnknownDevices(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "GET only", http.StatusMethodNotAllowed)
+		return
+	}
+	if !authorizeOrReject(w, r, authz.ActionDevicesRead, authz.ResourceRef{}) {
+		return
+	}
+	filter := storage.UnknownDeviceFilter{Enterprise: strings.TrimSpace(r.URL.Query().Get("enterprise"))}
+	if v := strings.TrimSpace(r.URL.Query().Get("limit")); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			http.Error(w, "invalid limit", http.StatusBadRequest)
+			return
+		}
+		filter.Limit = n
+	}
+	principal := getPrincipal(r)
+	if principal != nil && !principal.IsAdmin() {
+		filter.TenantIDs = principal.AllowedTenantIDs()
+		if len(filter.TenantIDs) == 0 {
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode([]*storage.UnknownDevice{})
+			return
+		}
+	}
+
+	devices, err := serverStore.ListUnknownDevices(r.Context(), filter)
+	if err != nil {
+		logError("Failed to list unknown devices", "error", err)
+		http.Error(w, "failed to list unknown devices", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(devices)
+}
+
+func capLength(s string, n int) string {
+	if len(s) > n {
+		return s[:n]
+	}
+	return s
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"printmaster/server/storage"
+)
+
+func TestUnknownDevicesBatchAndList(t *testing.T) {
+	store := SetupTestStore(t)
+	ctx := context.Background()
+	agents := []*storage.Agent{
+		{AgentID: "agent-a", Hostname: "a", Token: "ta", TenantID: "tenant-a", Status: "active", RegisteredAt: time.Now(), LastSeen: time.Now()},
+		{AgentID: "agent-b", Hostname: "b", Token: "tb", TenantID: "tenant-b", Status: "active", RegisteredAt: time.Now(), LastSeen: time.Now()},
+	}
+	for _, a := range agents {
+		if err := store.RegisterAgent(ctx, a); err != nil {
+			t.Fatalf("RegisterAgent: %v", err)
+		}
+	}
+
+	upload := func(agent *storage.Agent, count int) {
+		t.Helper()
+		body, _ := json.Marshal(map[string]interface{}{
+			"agent_id": agent.AgentID,
+			"devices": []map[string]interface{}{
+				{"sys_object_id": "1.3.6.1.4.1.55555.1", "sys_descr": "Acme MFP 3000", "enterprise": "55555", "count": count},
+				{"sys_object_id": "", "sys_descr": ""}, // nothing to report
+			},
+		})
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/unknown-devices/batch", bytes.NewReader(body))
+		req = req.WithContext(context.WithValue(req.Context(), agentContextKey, agent))
+		rr := httptest.NewRecorder()
+		handleUnknownDevicesBatch(rr, req)
+		if rr.Code != http.StatusOK || !bytes.Contains(rr.Body.Bytes(), []byte(`"stored":1`)) {
+			t.Fatalf("upload: %d %s", rr.Code, rr.Body.String())
+		}
+	}
+	upload(agents[0], 5)
+	upload(agents[0], 2) // agent restarted; the count doesn't go down
+	upload(agents[1], 3)
+
+	list := func(user *storage.User) []*storage.UnknownDevice {
+		t.Helper()
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/unknown-devices", nil)
+		rr := httptest.NewRecorder()
+		handleUnknownDevices(rr, InjectTestUser(req, user))
+		var out []*storage.UnknownDevice
+		if err := json.Unmarshal(rr.Body.Bytes(), &out); err != nil || rr.Code != http.StatusOK {
+			t.Fatalf("list: %d %s", rr.Code, rr.Body.String())
+		}
+		return out
+	}
+
+	all := list(NewTestUser(storage.RoleAdmin))
+	if len(all) != 1 || all[0].Agents != 2 || all[0].Sightings != 8 || all[0].Enterprise != "55555" {
+		t.Fatalf("unexpected admin list: %+v", all)
+	}
+	scoped := list(NewTestUser(storage.RoleViewer, "tenant-b"))
+	if len(scoped) != 1 || scoped[0].Agents != 1 || scoped[0].Sightings != 3 {
+		t.Fatalf("unexpected tenant list: %+v", scoped)
+	}
+}
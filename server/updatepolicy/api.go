@@ -25,6 +25,7 @@ type Store interface {
 	UpsertFleetUpdatePolicy(context.Context, *storage.FleetUpdatePolicy) error
 	DeleteFleetUpdatePolicy(context.Context, string) error
 	ListFleetUpdatePolicies(context.Context) ([]*storage.FleetUpdatePolicy, error)
+	ListAgents(context.Context) ([]*storage.Agent, error)
 }
 
 // APIOptions provides cross-cutting infrastructure for the HTTP layer.
@@ -33,6 +34,9 @@ type APIOptions struct {
 	Authorizer     func(*http.Request, authz.Action, authz.ResourceRef) error
 	ActorResolver  func(*http.Request) string
 	AuditLogger    func(*http.Request, *storage.AuditEntry)
+	// LatestVersion resolves the release agents would be offered; without it
+	// rollout simulations find no release for any agent.
+	LatestVersion LatestVersionFunc
 }
 
 // RouteConfig controls how HTTP handlers are registered.
@@ -49,6 +53,7 @@ type API struct {
 	authorizer    func(*http.Request, authz.Action, authz.ResourceRef) error
 	actorResolver func(*http.Request) string
 	auditLogger   func(*http.Request, *storage.AuditEntry)
+	latestVersion LatestVersionFunc
 }
 
 // NewAPI builds a new fleet update policy API instance.
@@ -63,6 +68,7 @@ func NewAPI(store Store, opts APIOptions) (*API, error) {
 		authorizer:    opts.Authorizer,
 		actorResolver: opts.ActorResolver,
 		auditLogger:   opts.AuditLogger,
+		latestVersion: opts.LatestVersion,
 	}, nil
 }
 
@@ -147,6 +153,11 @@ func (api *API) handleListPolicies(w http.ResponseWriter, r *http.Request) {
 	})
 	resp := make([]policyResponse, 0, len(policies))
 	for _, policy := range policies {
+		// Scoped roles see the global policy and their own tenants' policies
+		if policy.TenantID != storage.GlobalFleetPolicyTenantID &&
+			api.authorizer(r, authz.ActionTenantsRead, authz.ResourceRef{TenantIDs: []string{policy.TenantID}}) != nil {
+			continue
+		}
 		resp = append(resp, toPolicyResponse(policy))
 	}
 	writeJSON(w, http.StatusOK, resp)
@@ -159,9 +170,13 @@ func (api *API) handlePolicyRoute(w http.ResponseWriter, r *http.Request) {
 		http.NotFound(w, r)
 		return
 	}
-	// Disallow nested subpaths for now.
-	if strings.Contains(tenantID, "/") {
-		http.NotFound(w, r)
+	// The only nested path is the rollout simulation.
+	if tenant, rest, nested := strings.Cut(tenantID, "/"); nested {
+		if rest != "simulate" {
+			http.NotFound(w, r)
+			return
+		}
+		api.handleTenantPolicySimulateRoute(w, r, tenant)
 		return
 	}
 	api.handleTenantPolicy(w, r, tenantID)
@@ -169,11 +184,14 @@ func (api *API) handlePolicyRoute(w http.ResponseWriter, r *http.Request) {
 
 func (api *API) tenantSubresourceHandler() tenancy.TenantSubresourceHandler {
 	return func(w http.ResponseWriter, r *http.Request, tenantID, rest string) {
-		if strings.TrimSpace(rest) != "" {
+		switch strings.Trim(strings.TrimSpace(rest), "/") {
+		case "":
+			api.handleTenantPolicy(w, r, tenantID)
+		case "simulate":
+			api.handleTenantPolicySimulateRoute(w, r, tenantID)
+		default:
 			http.NotFound(w, r)
-			return
 		}
-		api.handleTenantPolicy(w, r, tenantID)
 	}
 }
 
@@ -201,6 +219,15 @@ func (api *API) handleTenantPolicy(w http.ResponseWriter, r *http.Request, tenan
 	}
 }
 
+func (api *API) handleTenantPolicySimulateRoute(w http.ResponseWriter, r *http.Request, tenantRef string) {
+	realTenantID, isGlobal := normalizePolicyTenantID(tenantRef)
+	if realTenantID == "" {
+		writeError(w, http.StatusBadRequest, "tenant id required")
+		return
+	}
+	api.handleTenantPolicySimulate(w, r, realTenantID, isGlobal)
+}
+
 func (api *API) handleTenantPolicyGet(w http.ResponseWriter, r *http.Request, tenantID string, isGlobal bool) {
 	resource := authz.ResourceRef{}
 	action := authz.ActionTenantsRead
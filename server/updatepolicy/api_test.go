@@ -17,6 +17,7 @@ import (
 
 type fakeStore struct {
 	policies   map[string]*storage.FleetUpdatePolicy
+	agents     []*storage.Agent
 	deletes    []string
 	lastUpsert *storage.FleetUpdatePolicy
 }
@@ -63,6 +64,10 @@ func (s *fakeStore) ListFleetUpdatePolicies(ctx context.Context) ([]*storage.Fle
 	return out, nil
 }
 
+func (s *fakeStore) ListAgents(ctx context.Context) ([]*storage.Agent, error) {
+	return s.agents, nil
+}
+
 func allowAllAuthorizer(_ *http.Request, _ authz.Action, _ authz.ResourceRef) error {
 	return nil
 }
@@ -0,0 +1,485 @@
+package updatepolicy
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/Masterminds/semver"
+
+	"printmaster/common/updatepolicy"
+	authz "printmaster/server/authz"
+	"printmaster/server/storage"
+)
+
+// A rollout simulation answers "what happens if this policy is saved": which
+// agents the policy governs, which of them would update and to what, in
+// which wave, and when each wave can start given the maintenance window.
+// It mirrors the agent's update checks (agent/autoupdate) and changes
+// nothing.
+
+const (
+	// defaultWaveSize is used for staggered rollouts without a batch size or
+	// concurrency limit.
+	defaultWaveSize = 10
+	// Business hours used to flag maintenance windows that can restart
+	// agents while sites are working (Monday to Friday, window timezone).
+	businessStartMinute = 8 * 60
+	businessEndMinute   = 18 * 60
+)
+
+// LatestVersionFunc returns the newest release an agent on the given
+// platform, architecture and channel would be offered ("" if none).
+type LatestVersionFunc func(ctx context.Context, platform, arch, channel string) (string, error)
+
+// Simulation is the dry-run result for one policy.
+type Simulation struct {
+	TenantID          string                  `json:"tenant_id"`
+	Policy            updatepolicy.PolicySpec `json:"policy"`
+	Start             time.Time               `json:"start"`
+	Enabled           bool                    `json:"enabled"`
+	CheckIntervalDays int                     `json:"check_interval_days"`
+	AgentsInScope     int                     `json:"agents_in_scope"`
+	Targeted          int                     `json:"targeted"`
+	Waves             []SimulatedWave         `json:"waves"`
+	Skipped           []SimulatedAgent        `json:"skipped"`
+	Warnings          []string                `json:"warnings"`
+}
+
+// SimulatedWave is one stage of the rollout. Ring numbers start at 1.
+type SimulatedWave struct {
+	Ring     int              `json:"ring"`
+	StartsAt time.Time        `json:"starts_at"`
+	EndsAt   time.Time        `json:"ends_at"`
+	Agents   []SimulatedAgent `json:"agents"`
+}
+
+// SimulatedAgent is an agent's expected outcome.
+type SimulatedAgent struct {
+	AgentID        string `json:"agent_id"`
+	Name           string `json:"name"`
+	TenantID       string `json:"tenant_id,omitempty"`
+	Platform       string `json:"platform"`
+	Arch           string `json:"arch,omitempty"`
+	CurrentVersion string `json:"current_version"`
+	TargetVersion  string `json:"target_version,omitempty"`
+	Offline        bool   `json:"offline,omitempty"`
+	Reason         string `json:"reason,omitempty"`
+}
+
+// decodeStrictJSON decodes an optional JSON body, rejecting unknown fields.
+func decodeStrictJSON(body io.Reader, v interface{}) error {
+	decoder := json.NewDecoder(io.LimitReader(body, 1<<20))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(v); err != nil && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("invalid json: %w", err)
+	}
+	return nil
+}
+
+type simulatePayload struct {
+	Policy *updatepolicy.PolicySpec `json:"policy"`
+	Start  *time.Time               `json:"start"`
+}
+
+func (api *API) handleTenantPolicySimulate(w http.ResponseWriter, r *http.Request, tenantID string, isGlobal bool) {
+	if r.Method != http.MethodPost {
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+	resource := authz.ResourceRef{}
+	action := authz.ActionTenantsRead
+	if !isGlobal {
+		resource = authz.ResourceRef{TenantIDs: []string{tenantID}}
+	} else {
+		action = authz.ActionSettingsFleetRead
+	}
+	if !api.authorize(w, r, action, resource) {
+		return
+	}
+	var payload simulatePayload
+	if err := decodeStrictJSON(r.Body, &payload); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	var spec updatepolicy.PolicySpec
+	if payload.Policy != nil {
+		spec = normalizePolicySpec(*payload.Policy)
+		if issues := validatePolicySpec(spec); len(issues) > 0 {
+			writeValidationError(w, issues)
+			return
+		}
+	} else {
+		// Without a proposed policy, preview the saved one
+		current, err := api.store.GetFleetUpdatePolicy(r.Context(), tenantID)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, "failed to fetch policy")
+			return
+		}
+		if current == nil {
+			writeError(w, http.StatusNotFound, "policy not configured")
+			return
+		}
+		spec = current.PolicySpec
+	}
+	start := time.Now().UTC()
+	if payload.Start != nil {
+		start = payload.Start.UTC()
+	}
+	sim, err := api.Simulate(r.Context(), tenantID, spec, start)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to simulate rollout")
+		return
+	}
+	writeJSON(w, http.StatusOK, sim)
+}
+
+// Simulate plans the rollout of spec for the agents it would govern: every
+// agent of the tenant, or for the global policy every agent whose tenant has
+// no policy of its own.
+func (api *API) Simulate(ctx context.Context, tenantID string, spec updatepolicy.PolicySpec, start time.Time) (*Simulation, error) {
+	agents, err := api.store.ListAgents(ctx)
+	if err != nil {
+		return nil, err
+	}
+	isGlobal := tenantID == storage.GlobalFleetPolicyTenantID
+	overridden := map[string]bool{}
+	if isGlobal {
+		policies, err := api.store.ListFleetUpdatePolicies(ctx)
+		if err != nil {
+			return nil, err
+		}
+		for _, p := range policies {
+			if p.TenantID != storage.GlobalFleetPolicyTenantID {
+				overridden[p.TenantID] = true
+			}
+		}
+	}
+
+	sim := &Simulation{
+		TenantID:          displayPolicyTenantID(tenantID),
+		Policy:            clonePolicySpec(spec),
+		Start:             start,
+		Enabled:           spec.UpdateCheckDays > 0,
+		CheckIntervalDays: spec.UpdateCheckDays,
+		Waves:             []SimulatedWave{},
+		Skipped:           []SimulatedAgent{},
+		Warnings:          []string{},
+	}
+
+	type releaseKey struct{ platform, arch, channel string }
+	latest := map[releaseKey]string{}
+	var targets []SimulatedAgent
+	for _, agent := range agents {
+		if isGlobal {
+			if agent.TenantID != "" && overridden[agent.TenantID] {
+				continue
+			}
+		} else if agent.TenantID != tenantID {
+			continue
+		}
+		sim.AgentsInScope++
+		sa := SimulatedAgent{
+			AgentID:        agent.AgentID,
+			Name:           agent.Name,
+			TenantID:       agent.TenantID,
+			Platform:       agent.Platform,
+			Arch:           agent.Architecture,
+			CurrentVersion: agent.Version,
+			Offline:        agent.Status == "offline",
+		}
+		if sa.Name == "" {
+			sa.Name = agent.Hostname
+		}
+		if !sim.Enabled {
+			sa.Reason = "automatic updates disabled (update_check_days is 0)"
+			sim.Skipped = append(sim.Skipped, sa)
+			continue
+		}
+		key := releaseKey{agent.Platform, agent.Architecture, agentChannel(agent)}
+		version, ok := latest[key]
+		if !ok {
+			if api.latestVersion != nil {
+				if version, err = api.latestVersion(ctx, key.platform, key.arch, key.channel); err != nil {
+					return nil, err
+				}
+			}
+			latest[key] = version
+		}
+		if version == "" {
+			sa.Reason = fmt.Sprintf("no %s release for %s/%s", key.channel, agent.Platform, agent.Architecture)
+			sim.Skipped = append(sim.Skipped, sa)
+			continue
+		}
+		if reason := upgradeBlocked(agent.Version, version, spec); reason != "" {
+			sa.Reason = reason
+			sim.Skipped = append(sim.Skipped, sa)
+			continue
+		}
+		sa.TargetVersion = version
+		targets = append(targets, sa)
+	}
+	sim.Targeted = len(targets)
+
+	sort.Slice(targets, func(i, j int) bool {
+		if targets[i].TenantID != targets[j].TenantID {
+			return targets[i].TenantID < targets[j].TenantID
+		}
+		if !strings.EqualFold(targets[i].Name, targets[j].Name) {
+			return strings.ToLower(targets[i].Name) < strings.ToLower(targets[j].Name)
+		}
+		return targets[i].AgentID < targets[j].AgentID
+	})
+	sort.Slice(sim.Skipped, func(i, j int) bool {
+		return strings.ToLower(sim.Skipped[i].Name) < strings.ToLower(sim.Skipped[j].Name)
+	})
+
+	sim.planWaves(targets)
+	sim.addWarnings(targets)
+	return sim, nil
+}
+
+// planWaves splits targets into waves and schedules each at the first
+// maintenance window opening after the previous wave's delay.
+func (sim *Simulation) planWaves(targets []SimulatedAgent) {
+	if len(targets) == 0 {
+		return
+	}
+	rc := sim.Policy.RolloutControl
+	size := len(targets)
+	if rc.Staggered {
+		size = rc.BatchSize
+		if rc.MaxConcurrent > 0 && (size <= 0 || size > rc.MaxConcurrent) {
+			size = rc.MaxConcurrent
+		}
+		if size <= 0 {
+			size = defaultWaveSize
+		}
+	}
+	window := newWindowSchedule(sim.Policy.MaintenanceWindow)
+	delay := time.Duration(rc.DelayBetweenWaves) * time.Second
+	jitter := time.Duration(rc.JitterSeconds) * time.Second
+	earliest := sim.Start
+	for i := 0; i*size < len(targets); i++ {
+		end := (i + 1) * size
+		if end > len(targets) {
+			end = len(targets)
+		}
+		startsAt := window.nextOpen(earliest)
+		sim.Waves = append(sim.Waves, SimulatedWave{
+			Ring:     i + 1,
+			StartsAt: startsAt,
+			EndsAt:   startsAt.Add(jitter),
+			Agents:   targets[i*size : end],
+		})
+		earliest = startsAt.Add(delay)
+	}
+}
+
+func (sim *Simulation) addWarnings(targets []SimulatedAgent) {
+	spec := sim.Policy
+	warn := func(format string, args ...interface{}) {
+		sim.Warnings = append(sim.Warnings, fmt.Sprintf(format, args...))
+	}
+	if !sim.Enabled {
+		warn("Automatic updates are disabled: update_check_days is 0.")
+		return
+	}
+	if len(targets) == 0 {
+		return
+	}
+	mw := spec.MaintenanceWindow
+	if !mw.Enabled {
+		warn("No maintenance window: agents restart whenever their update check runs, including business hours.")
+	} else {
+		window := newWindowSchedule(mw)
+		if window.invalidZone {
+			warn("Unknown timezone %q: agents will use UTC.", mw.Timezone)
+		}
+		if window.length == 0 {
+			warn("The maintenance window is empty (start equals end): agents will never update.")
+		} else if window.overlapsBusinessHours() {
+			warn("The maintenance window overlaps business hours (Mon-Fri %02d:00-%02d:00 %s).",
+				businessStartMinute/60, businessEndMinute/60, window.loc.String())
+		}
+		if jitter := time.Duration(spec.RolloutControl.JitterSeconds) * time.Second; jitter > window.length {
+			warn("Jitter (%s) is longer than the maintenance window (%s); some agents will wait for the next window.", jitter, window.length)
+		}
+	}
+	if !spec.RolloutControl.Staggered && len(targets) > 1 {
+		warn("Rollout is not staggered: all %d agents may update at the same time.", len(targets))
+	}
+	offline, majors := 0, 0
+	for _, t := range targets {
+		if t.Offline {
+			offline++
+		}
+		if crossesMajor(t.CurrentVersion, t.TargetVersion) {
+			majors++
+		}
+	}
+	if majors > 0 {
+		warn("%d agent(s) would cross a major version.", majors)
+	}
+	if offline > 0 {
+		warn("%d targeted agent(s) are offline and will update when they reconnect.", offline)
+	}
+}
+
+// agentChannel mirrors the agent's default update channel.
+func agentChannel(agent *storage.Agent) string {
+	if agent.BuildType == "dev" {
+		return "dev"
+	}
+	return "stable"
+}
+
+// upgradeBlocked explains why an agent on current would not install latest
+// under spec ("" if it would). It follows the agent's isUpdateNeeded and
+// isVersionAllowed checks.
+func upgradeBlocked(current, latest string, spec updatepolicy.PolicySpec) string {
+	cur := parseVersion(current)
+	target := parseVersion(latest)
+	if cur == nil || target == nil {
+		if current == latest {
+			return "already on " + latest
+		}
+		return ""
+	}
+	if spec.TargetVersion != "" {
+		if pinned := parseVersion(spec.TargetVersion); pinned != nil && !target.Equal(pinned) {
+			return fmt.Sprintf("latest release %s is not the pinned target %s", latest, spec.TargetVersion)
+		}
+	}
+	if !target.GreaterThan(cur) {
+		return "already on " + current
+	}
+	switch spec.VersionPinStrategy {
+	case updatepolicy.VersionPinMajor:
+		if target.Major() != cur.Major() && !spec.AllowMajorUpgrade {
+			return fmt.Sprintf("%s is a major upgrade and major upgrades are not allowed", latest)
+		}
+	case updatepolicy.VersionPinMinor:
+		if (target.Major() != cur.Major() || target.Minor() != cur.Minor()) && !spec.AllowMajorUpgrade {
+			return fmt.Sprintf("%s leaves the pinned %d.%d line", latest, cur.Major(), cur.Minor())
+		}
+	case updatepolicy.VersionPinPatch:
+		return "version is pinned (patch strategy)"
+	}
+	return ""
+}
+
+func crossesMajor(current, target string) bool {
+	cur, tgt := parseVersion(current), parseVersion(target)
+	return cur != nil && tgt != nil && tgt.Major() != cur.Major()
+}
+
+func parseVersion(raw string) *semver.Version {
+	trimmed := strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(raw), "v"))
+	if trimmed == "" {
+		return nil
+	}
+	v, err := semver.NewVersion(trimmed)
+	if err != nil {
+		return nil
+	}
+	return v
+}
+
+// windowSchedule evaluates a maintenance window the way agents do.
+type windowSchedule struct {
+	mw          updatepolicy.MaintenanceWindow
+	loc         *time.Location
+	invalidZone bool
+	start       int // minutes after midnight
+	length      time.Duration
+}
+
+func newWindowSchedule(mw updatepolicy.MaintenanceWindow) windowSchedule {
+	ws := windowSchedule{mw: mw, loc: time.UTC}
+	if mw.Timezone != "" {
+		if loc, err := time.LoadLocation(mw.Timezone); err == nil {
+			ws.loc = loc
+		} else {
+			ws.invalidZone = true
+		}
+	}
+	ws.start = mw.StartHour*60 + mw.StartMin
+	end := mw.EndHour*60 + mw.EndMin
+	if end < ws.start {
+		end += 24 * 60
+	}
+	ws.length = time.Duration(end-ws.start) * time.Minute
+	return ws
+}
+
+func (ws windowSchedule) dayAllowed(day time.Weekday) bool {
+	if len(ws.mw.DaysOfWeek) == 0 {
+		return true
+	}
+	for _, d := range ws.mw.DaysOfWeek {
+		if d == int(day) {
+			return true
+		}
+	}
+	return false
+}
+
+// contains reports whether an agent checking at t would be inside the window.
+func (ws windowSchedule) contains(t time.Time) bool {
+	if !ws.mw.Enabled {
+		return true
+	}
+	local := t.In(ws.loc)
+	if !ws.dayAllowed(local.Weekday()) {
+		return false
+	}
+	now := local.Hour()*60 + local.Minute()
+	end := (ws.start + int(ws.length/time.Minute)) % (24 * 60)
+	if ws.start <= end {
+		return now >= ws.start && now < end
+	}
+	return now >= ws.start || now < end
+}
+
+// nextOpen returns the first time at or after t when updates may run.
+func (ws windowSchedule) nextOpen(t time.Time) time.Time {
+	if !ws.mw.Enabled || ws.contains(t) {
+		return t
+	}
+	local := t.In(ws.loc)
+	for d := 0; d <= 7; d++ {
+		day := local.AddDate(0, 0, d)
+		open := time.Date(day.Year(), day.Month(), day.Day(), ws.mw.StartHour, ws.mw.StartMin, 0, 0, ws.loc)
+		if open.After(t) && ws.contains(open) {
+			return open.UTC()
+		}
+	}
+	return t
+}
+
+// overlapsBusinessHours reports whether the window, including any part past
+// midnight, falls on a weekday between businessStartMinute and
+// businessEndMinute.
+func (ws windowSchedule) overlapsBusinessHours() bool {
+	weekday := func(d time.Weekday) bool { return d >= time.Monday && d <= time.Friday }
+	end := ws.start + int(ws.length/time.Minute)
+	for day := time.Sunday; day <= time.Saturday; day++ {
+		if !ws.dayAllowed(day) {
+			continue
+		}
+		if weekday(day) && ws.start < businessEndMinute && end > businessStartMinute {
+			return true
+		}
+		if end > 24*60 && weekday((day+1)%7) && end-24*60 > businessStartMinute {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,187 @@
+package updatepolicy
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"printmaster/common/updatepolicy"
+	"printmaster/server/storage"
+)
+
+func simulationStore() *fakeStore {
+	store := newFakeStore()
+	store.agents = []*storage.Agent{
+		{AgentID: "a1", Name: "alpha", TenantID: "tenant-a", Platform: "linux", Architecture: "amd64", Version: "1.4.0", Status: "active"},
+		{AgentID: "a2", Name: "bravo", TenantID: "tenant-a", Platform: "linux", Architecture: "amd64", Version: "1.4.2", Status: "offline"},
+		{AgentID: "a3", Name: "charlie", TenantID: "tenant-a", Platform: "windows", Architecture: "amd64", Version: "1.5.0", Status: "active"},
+		{AgentID: "a4", Name: "delta", TenantID: "tenant-a", Platform: "linux", Architecture: "amd64", Version: "0.9.0", Status: "active"},
+		{AgentID: "a5", Name: "echo", TenantID: "tenant-a", Platform: "darwin", Architecture: "arm64", Version: "1.4.0", Status: "active"},
+		{AgentID: "b1", Name: "other", TenantID: "tenant-b", Platform: "linux", Architecture: "amd64", Version: "1.4.0", Status: "active"},
+	}
+	return store
+}
+
+func latestReleases(ctx context.Context, platform, arch, channel string) (string, error) {
+	switch platform {
+	case "linux", "windows":
+		return "1.5.0", nil
+	}
+	return "", nil
+}
+
+func TestSimulateRollout(t *testing.T) {
+	store := simulationStore()
+	api, err := NewAPI(store, APIOptions{Authorizer: allowAllAuthorizer, LatestVersion: latestReleases})
+	if err != nil {
+		t.Fatalf("NewAPI failed: %v", err)
+	}
+	spec := updatepolicy.PolicySpec{
+		UpdateCheckDays:    1,
+		VersionPinStrategy: updatepolicy.VersionPinMajor,
+		MaintenanceWindow: updatepolicy.MaintenanceWindow{
+			Enabled: true, StartHour: 22, EndHour: 4, Timezone: "UTC", DaysOfWeek: []int{0, 1, 2, 3, 4, 5, 6},
+		},
+		RolloutControl: updatepolicy.RolloutControl{Staggered: true, BatchSize: 1, DelayBetweenWaves: 3600, JitterSeconds: 600},
+	}
+	// Monday 10:00 UTC, outside the window
+	start := time.Date(2026, 3, 2, 10, 0, 0, 0, time.UTC)
+	sim, err := api.Simulate(context.Background(), "tenant-a", spec, start)
+	if err != nil {
+		t.Fatalf("Simulate failed: %v", err)
+	}
+	if sim.AgentsInScope != 5 || sim.Targeted != 2 {
+		t.Fatalf("scope %d targeted %d, want 5 and 2", sim.AgentsInScope, sim.Targeted)
+	}
+	if len(sim.Waves) != 2 || sim.Waves[0].Agents[0].AgentID != "a1" || sim.Waves[1].Agents[0].AgentID != "a2" {
+		t.Fatalf("unexpected waves %+v", sim.Waves)
+	}
+	if want := time.Date(2026, 3, 2, 22, 0, 0, 0, time.UTC); !sim.Waves[0].StartsAt.Equal(want) {
+		t.Errorf("wave 1 starts %s, want %s", sim.Waves[0].StartsAt, want)
+	}
+	if want := time.Date(2026, 3, 2, 23, 0, 0, 0, time.UTC); !sim.Waves[1].StartsAt.Equal(want) {
+		t.Errorf("wave 2 starts %s, want %s", sim.Waves[1].StartsAt, want)
+	}
+	if got := sim.Waves[0].EndsAt.Sub(sim.Waves[0].StartsAt); got != 10*time.Minute {
+		t.Errorf("wave 1 spread %s, want jitter of 10m", got)
+	}
+	reasons := map[string]string{}
+	for _, s := range sim.Skipped {
+		reasons[s.AgentID] = s.Reason
+	}
+	if !strings.HasPrefix(reasons["a3"], "already on") || !strings.Contains(reasons["a4"], "major") || !strings.HasPrefix(reasons["a5"], "no stable release") {
+		t.Errorf("unexpected skip reasons %v", reasons)
+	}
+	if !containsWarning(sim.Warnings, "offline") || containsWarning(sim.Warnings, "business hours") {
+		t.Errorf("unexpected warnings %v", sim.Warnings)
+	}
+
+	// No window and no staggering: everything at once, flagged
+	spec.MaintenanceWindow = updatepolicy.MaintenanceWindow{}
+	spec.RolloutControl = updatepolicy.RolloutControl{}
+	spec.AllowMajorUpgrade = true
+	sim, _ = api.Simulate(context.Background(), "tenant-a", spec, start)
+	if sim.Targeted != 3 || len(sim.Waves) != 1 || !sim.Waves[0].StartsAt.Equal(start) {
+		t.Fatalf("unexpected unstaggered plan %+v", sim)
+	}
+	for _, want := range []string{"No maintenance window", "not staggered", "major version"} {
+		if !containsWarning(sim.Warnings, want) {
+			t.Errorf("missing warning %q in %v", want, sim.Warnings)
+		}
+	}
+}
+
+func TestSimulateGlobalSkipsTenantOverrides(t *testing.T) {
+	store := simulationStore()
+	store.policies["tenant-a"] = &storage.FleetUpdatePolicy{TenantID: "tenant-a"}
+	api, _ := NewAPI(store, APIOptions{Authorizer: allowAllAuthorizer, LatestVersion: latestReleases})
+	spec := updatepolicy.PolicySpec{UpdateCheckDays: 7, VersionPinStrategy: updatepolicy.VersionPinMinor}
+	sim, err := api.Simulate(context.Background(), storage.GlobalFleetPolicyTenantID, spec, time.Now())
+	if err != nil {
+		t.Fatalf("Simulate failed: %v", err)
+	}
+	if sim.TenantID != "global" || sim.AgentsInScope != 1 || sim.Targeted != 0 {
+		t.Fatalf("unexpected global simulation %+v", sim)
+	}
+	if len(sim.Skipped) != 1 || !strings.Contains(sim.Skipped[0].Reason, "pinned 1.4 line") {
+		t.Errorf("unexpected skipped %+v", sim.Skipped)
+	}
+}
+
+func TestWindowBusinessHours(t *testing.T) {
+	cases := []struct {
+		mw   updatepolicy.MaintenanceWindow
+		want bool
+	}{
+		{updatepolicy.MaintenanceWindow{Enabled: true, StartHour: 1, EndHour: 5}, false},
+		{updatepolicy.MaintenanceWindow{Enabled: true, StartHour: 12, EndHour: 13}, true},
+		{updatepolicy.MaintenanceWindow{Enabled: true, StartHour: 12, EndHour: 13, DaysOfWeek: []int{0, 6}}, false},
+		// Sunday night into Monday morning
+		{updatepolicy.MaintenanceWindow{Enabled: true, StartHour: 22, EndHour: 10, DaysOfWeek: []int{0}}, true},
+	}
+	for i, tc := range cases {
+		if got := newWindowSchedule(tc.mw).overlapsBusinessHours(); got != tc.want {
+			t.Errorf("case %d: overlapsBusinessHours = %v, want %v", i, got, tc.want)
+		}
+	}
+}
+
+func TestHandleSimulateRoute(t *testing.T) {
+	store := simulationStore()
+	api, _ := NewAPI(store, APIOptions{Authorizer: allowAllAuthorizer, LatestVersion: latestReleases})
+
+	// No saved policy and none proposed
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/update-policies/tenant-a/simulate", nil)
+	rr := httptest.NewRecorder()
+	api.handlePolicyRoute(rr, req)
+	if rr.Code != http.StatusNotFound {
+		t.Fatalf("expected 404 without policy, got %d", rr.Code)
+	}
+
+	body, _ := json.Marshal(map[string]interface{}{
+		"policy": map[string]interface{}{"update_check_days": 1, "version_pin_strategy": "major"},
+		"start":  "2026-03-02T10:00:00Z",
+	})
+	req = httptest.NewRequest(http.MethodPost, "/api/v1/update-policies/tenant-a/simulate", bytes.NewReader(body))
+	rr = httptest.NewRecorder()
+	api.handlePolicyRoute(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	var sim Simulation
+	if err := json.Unmarshal(rr.Body.Bytes(), &sim); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if sim.Targeted != 2 || len(store.policies) != 0 {
+		t.Errorf("unexpected simulation %+v (policies saved: %d)", sim, len(store.policies))
+	}
+
+	req = httptest.NewRequest(http.MethodPost, "/api/v1/update-policies/tenant-a/simulate",
+		strings.NewReader(`{"policy":{"update_check_days":-1}}`))
+	rr = httptest.NewRecorder()
+	api.handlePolicyRoute(rr, req)
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 for invalid policy, got %d", rr.Code)
+	}
+
+	req = httptest.NewRequest(http.MethodPost, "/api/v1/update-policies/tenant-a/other", nil)
+	rr = httptest.NewRecorder()
+	api.handlePolicyRoute(rr, req)
+	if rr.Code != http.StatusNotFound {
+		t.Errorf("expected 404 for unknown subpath, got %d", rr.Code)
+	}
+}
+
+func containsWarning(warnings []string, substr string) bool {
+	for _, w := range warnings {
+		if strings.Contains(w, substr) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,577 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"printmaster/server/authz"
+	"printmaster/server/storage"
+	"printmaster/server/vendorcloud"
+)
+
+const (
+	vendorWebhookPath        = "/api/v1/integrations/webhooks/"
+	vendorWebhookMaxBody     = 1 << 20
+	vendorWebhookDefaultSkew = 5 * time.Minute
+	vendorDefaultLimit       = 100
+	vendorMaxLimit           = 1000
+)
+
+// vendorSources lists the supported vendor clouds in display order.
+var vendorSources = []string{vendorcloud.SourceHPSDS, vendorcloud.SourceLexmarkCloud}
+
+// vendorWebhookTenantPath is where a tenant's vendor cloud posts webhooks.
+func vendorWebhookTenantPath(source, tenantID string) string {
+	return vendorWebhookPath + source + "/" + tenantID
+}
+
+// newVendorWebhookHandler serves POST
+// /api/v1/integrations/webhooks/{source}/{tenant_id}. Vendor clouds
+// authenticate with the tenant's HMAC secret for the source rather than a
+// session, so the route is public; tenants without a secret are not found.
+func newVendorWebhookHandler(cfg VendorWebhooksConfig) http.HandlerFunc {
+	maxSkew := time.Duration(cfg.MaxSkewSeconds) * time.Second
+	if maxSkew <= 0 {
+		maxSkew = vendorWebhookDefaultSkew
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "POST only", http.StatusMethodNotAllowed)
+			return
+		}
+		parts := strings.Split(strings.Trim(strings.TrimPrefix(r.URL.Path, vendorWebhookPath), "/"), "/")
+		if len(parts) != 2 || !vendorcloud.ValidSource(parts[0]) || parts[1] == "" {
+			http.NotFound(w, r)
+			return
+		}
+		source, tenantID := parts[0], parts[1]
+		ctx := r.Context()
+		secret, err := serverStore.GetVendorWebhookSecret(ctx, tenantID, source)
+		if err != nil {
+			logError("Vendor webhook: failed to load secret", "source", source, "tenant_id", tenantID, "error", err)
+			http.Error(w, "failed to verify delivery", http.StatusInternalServerError)
+			return
+		}
+		if secret == nil {
+			http.NotFound(w, r)
+			return
+		}
+
+		body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, vendorWebhookMaxBody))
+		if err != nil {
+			http.Error(w, "payload too large", http.StatusRequestEntityTooLarge)
+			return
+		}
+		clientIP := extractClientIP(r)
+		timestamp := strings.TrimSpace(r.Header.Get(vendorcloud.HeaderTimestamp))
+		deliveryID := strings.TrimSpace(r.Header.Get(vendorcloud.HeaderDelivery))
+		if err := vendorcloud.Verify([]byte(secret.Secret), timestamp, deliveryID,
+			r.Header.Get(vendorcloud.HeaderSignature), body, time.Now(), maxSkew); err != nil {
+			logWarn("Rejected vendor webhook", "source", source, "tenant_id", tenantID, "ip", clientIP, "error", err)
+			http.Error(w, "invalid signature", http.StatusUnauthorized)
+			return
+		}
+
+		// Vendors retry on timeouts, so a repeated delivery is acknowledged
+		// without storing its readings or raising its alerts again. The
+		// delivery ID is signed; without one, a replay inside the clock skew
+		// repeats the signed timestamp and body, so their hash stands in.
+		if deliveryID == "" {
+			sum := sha256.Sum256(append([]byte(timestamp+"."), body...))
+			deliveryID = hex.EncodeToString(sum[:16])
+		}
+		prev, err := serverStore.GetVendorWebhookDelivery(ctx, tenantID, source, deliveryID)
+		if err != nil {
+			logError("Vendor webhook: failed to look up delivery", "source", source, "error", err)
+			http.Error(w, "failed to store delivery", http.StatusInternalServerError)
+			return
+		}
+		if prev != nil {
+			writeVendorWebhookResult(w, http.StatusOK, map[string]interface{}{"duplicate": true, "delivery": prev})
+			return
+		}
+
+		delivery := &storage.VendorWebhookDelivery{
+			TenantID:   tenantID,
+			Source:     source,
+			DeliveryID: deliveryID,
+			SourceIP:   clientIP,
+			ReceivedAt: time.Now().UTC(),
+		}
+		events, err := vendorcloud.Parse(source, body)
+		if err != nil {
+			delivery.Status = storage.VendorDeliveryRejected
+			delivery.Error = err.Error()
+			if serr := serverStore.SaveVendorWebhookDelivery(ctx, delivery, nil); serr != nil {
+				logError("Vendor webhook: failed to store rejected delivery", "source", source, "error", serr)
+			}
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		readings, alerts, err := applyVendorEvents(ctx, source, tenantID, events)
+		if err != nil {
+			logError("Vendor webhook: failed to apply events", "source", source, "error", err)
+			http.Error(w, "failed to apply events", http.StatusInternalServerError)
+			return
+		}
+		delivery.Status = storage.VendorDeliveryAccepted
+		delivery.Events = len(readings)
+		for _, rd := range readings {
+			if rd.Reconcile == storage.VendorReconcileUnmatched {
+				delivery.Unmatched++
+			} else {
+				delivery.Matched++
+			}
+		}
+		if err := serverStore.SaveVendorWebhookDelivery(ctx, delivery, readings); err != nil {
+			logError("Vendor webhook: failed to store delivery", "source", source, "error", err)
+			http.Error(w, "failed to store delivery", http.StatusInternalServerError)
+			return
+		}
+		logInfo("Vendor webhook received", "source", source, "tenant_id", tenantID, "delivery", deliveryID,
+			"events", delivery.Events, "matched", delivery.Matched, "unmatched", delivery.Unmatched)
+
+		writeVendorWebhookResult(w, http.StatusOK, map[string]interface{}{
+			"delivery":        delivery,
+			"alerts_created":  alerts.created,
+			"alerts_resolved": alerts.resolved,
+		})
+	}
+}
+
+type vendorAlertCounts struct {
+	created, resolved int
+}
+
+// vendorDevice caches what applyVendorEvents needs per serial.
+type vendorDevice struct {
+	device   *storage.Device
+	tenantID string
+	latest   *storage.MetricsSnapshot
+}
+
+// applyVendorEvents matches events to the tenant's devices by serial,
+// reconciles supply levels and counters with the agent's latest metrics and
+// raises or resolves a device alert for each vendor alert. Every reading
+// belongs to the delivering tenant, matched or not.
+func applyVendorEvents(ctx context.Context, source, tenantID string, events []vendorcloud.Event) ([]*storage.VendorReading, vendorAlertCounts, error) {
+	var counts vendorAlertCounts
+	devices := make(map[string]*vendorDevice)
+	readings := make([]*storage.VendorReading, 0, len(events))
+
+	for _, e := range events {
+		reading := &storage.VendorReading{
+			Source:     source,
+			TenantID:   tenantID,
+			Serial:     e.Serial,
+			Kind:       e.Kind,
+			Metric:     e.Metric,
+			Value:      e.Value,
+			Severity:   e.Severity,
+			State:      e.State,
+			Message:    e.Message,
+			ObservedAt: e.ObservedAt,
+		}
+		readings = append(readings, reading)
+
+		dev, ok := devices[e.Serial]
+		if !ok {
+			dev = lookupVendorDevice(ctx, tenantID, e.Serial)
+			devices[e.Serial] = dev
+		}
+		if dev == nil {
+			reading.Reconcile = storage.VendorReconcileUnmatched
+			continue
+		}
+		reading.AgentID = dev.device.AgentID
+
+		if e.Kind == vendorcloud.KindAlert {
+			alertID, created, resolved, err := applyVendorAlert(ctx, source, dev, e)
+			if err != nil {
+				return nil, counts, err
+			}
+			reading.AlertID = alertID
+			counts.created += created
+			counts.resolved += resolved
+			continue
+		}
+		rec := vendorcloud.Reconcile(e, dev.latest)
+		reading.Reconcile = rec.Status
+		reading.AgentValue = rec.AgentValue
+		reading.AgentObservedAt = rec.AgentObservedAt
+	}
+	return readings, counts, nil
+}
+
+// lookupVendorDevice returns the tenant's device with serial, or nil when no
+// device of that tenant has it. A serial reported by another tenant's agent is
+// treated as unknown so one tenant's webhooks cannot touch another's devices.
+func lookupVendorDevice(ctx context.Context, tenantID, serial string) *vendorDevice {
+	device, err := serverStore.GetDevice(ctx, serial)
+	if err != nil || device == nil || device.AgentID == "" {
+		return nil
+	}
+	agent, err := serverStore.GetAgent(ctx, device.AgentID)
+	if err != nil || agent == nil || agent.TenantID != tenantID {
+		return nil
+	}
+	dev := &vendorDevice{device: device, tenantID: tenantID}
+	latest, err := serverStore.GetLatestMetrics(ctx, serial)
+	if err != nil {
+		logWarn("Vendor webhook: failed to load metrics", "serial", serial, "error", err)
+	}
+	dev.latest = latest
+	return dev
+}
+
+// applyVendorAlert raises a device alert for an active vendor alert, reusing
+// one already open for the same source and code, or resolves those open
+// alerts when the vendor clears it. Returns the alert the reading refers to.
+func applyVendorAlert(ctx context.Context, source string, dev *vendorDevice, e vendorcloud.Event) (int64, int, int, error) {
+	active, err := serverStore.ListActiveAlerts(ctx, storage.AlertFilters{Type: storage.AlertTypeDeviceError, TenantID: dev.tenantID})
+	if err != nil {
+		return 0, 0, 0, fmt.Errorf("list alerts: %w", err)
+	}
+	var matches []storage.Alert
+	for _, a := range active {
+		if a.DeviceSerial == e.Serial && vendorAlertMatches(a.Details, source, e.Metric) {
+			matches = append(matches, a)
+		}
+	}
+
+	if e.State == vendorcloud.AlertCleared {
+		var alertID int64
+		resolved := 0
+		for _, a := range matches {
+			if err := serverStore.ResolveAlert(ctx, a.ID); err != nil {
+				logWarn("Failed to resolve vendor alert", "alert_id", a.ID, "error", err)
+				continue
+			}
+			alertID = a.ID
+			resolved++
+		}
+		return alertID, 0, resolved, nil
+	}
+	if len(matches) > 0 {
+		return matches[0].ID, 0, 0, nil
+	}
+
+	summary := e.Message
+	if summary == "" {
+		summary = "alert " + e.Metric
+	}
+	details, _ := json.Marshal(map[string]string{"source": source, "code": e.Metric})
+	id, err := serverStore.CreateAlert(ctx, &storage.Alert{
+		Type:         storage.AlertTypeDeviceError,
+		Severity:     e.Severity,
+		Scope:        storage.AlertScopeDevice,
+		Status:       storage.AlertStatusActive,
+		TenantID:     dev.tenantID,
+		AgentID:      dev.device.AgentID,
+		DeviceSerial: e.Serial,
+		Title:        vendorcloud.SourceName(source) + ": " + summary,
+		Message:      e.Message,
+		Details:      string(details),
+		TriggeredAt:  e.ObservedAt,
+	})
+	if err != nil {
+		return 0, 0, 0, fmt.Errorf("create alert: %w", err)
+	}
+	return id, 1, 0, nil
+}
+
+// vendorAlertMatches reports whether an alert's details name source and code.
+func vendorAlertMatches(details, source, code string) bool {
+	var d map[string]interface{}
+	if details == "" || json.Unmarshal([]byte(details), &d) != nil {
+		return false
+	}
+	return d["source"] == source && d["code"] == code
+}
+
+// newVendorWebhookInboxHandler serves GET /api/v1/integrations/webhooks
+// ?tenant_id=&source=&limit= with the supported sources, the tenants that
+// have a secret for each, and the received deliveries, newest first.
+func newVendorWebhookInboxHandler(cfg VendorWebhooksConfig) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "GET only", http.StatusMethodNotAllowed)
+			return
+		}
+		if !authorizeOrReject(w, r, authz.ActionSettingsServerRead, authz.ResourceRef{}) {
+			return
+		}
+		q := r.URL.Query()
+		source := strings.TrimSpace(q.Get("source"))
+		if source != "" && !vendorcloud.ValidSource(source) {
+			http.Error(w, "unknown source", http.StatusBadRequest)
+			return
+		}
+		limit, ok := vendorListLimit(w, q.Get("limit"))
+		if !ok {
+			return
+		}
+
+		ctx := r.Context()
+		deliveries, err := serverStore.ListVendorWebhookDeliveries(ctx, storage.VendorWebhookDeliveryFilter{
+			TenantID: strings.TrimSpace(q.Get("tenant_id")), Source: source, Limit: limit})
+		if err != nil {
+			logError("Vendor webhook: failed to list deliveries", "error", err)
+			http.Error(w, "failed to load deliveries", http.StatusInternalServerError)
+			return
+		}
+		if deliveries == nil {
+			deliveries = []*storage.VendorWebhookDelivery{}
+		}
+		secrets, err := serverStore.ListVendorWebhookSecrets(ctx)
+		if err != nil {
+			logError("Vendor webhook: failed to list secrets", "error", err)
+			http.Error(w, "failed to load secrets", http.StatusInternalServerError)
+			return
+		}
+
+		type sourceStatus struct {
+			Source  string   `json:"source"`
+			Name    string   `json:"name"`
+			Path    string   `json:"path"`
+			Enabled bool     `json:"enabled"`
+			Tenants []string `json:"tenants"`
+		}
+		sources := []sourceStatus{}
+		for _, src := range vendorSources {
+			status := sourceStatus{
+				Source:  src,
+				Name:    vendorcloud.SourceName(src),
+				Path:    vendorWebhookPath + src + "/{tenant_id}",
+				Enabled: cfg.Enabled,
+				Tenants: []string{},
+			}
+			for _, sec := range secrets {
+				if sec.Source == src {
+					status.Tenants = append(status.Tenants, sec.TenantID)
+				}
+			}
+			sources = append(sources, status)
+		}
+		writeVendorWebhookResult(w, http.StatusOK, map[string]interface{}{"sources": sources, "deliveries": deliveries})
+	}
+}
+
+// handleVendorWebhookSecrets serves /api/v1/integrations/webhook-secrets.
+// GET lists the tenants and sources with a secret; POST {tenant_id, source}
+// generates (or rotates) a secret and returns it once together with the
+// delivery path; DELETE ?tenant_id=&source= stops accepting that tenant's
+// deliveries from the source.
+func handleVendorWebhookSecrets(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	switch r.Method {
+	case http.MethodGet:
+		if !authorizeOrReject(w, r, authz.ActionSettingsServerRead, authz.ResourceRef{}) {
+			return
+		}
+		secrets, err := serverStore.ListVendorWebhookSecrets(ctx)
+		if err != nil {
+			logError("Vendor webhook: failed to list secrets", "error", err)
+			http.Error(w, "failed to load secrets", http.StatusInternalServerError)
+			return
+		}
+		type secretInfo struct {
+			*storage.VendorWebhookSecret
+			Path string `json:"path"`
+		}
+		out := []secretInfo{}
+		for _, sec := range secrets {
+			out = append(out, secretInfo{sec, vendorWebhookTenantPath(sec.Source, sec.TenantID)})
+		}
+		writeVendorWebhookResult(w, http.StatusOK, map[string]interface{}{"secrets": out})
+
+	case http.MethodPost:
+		if !authorizeOrReject(w, r, authz.ActionSettingsServerWrite, authz.ResourceRef{}) {
+			return
+		}
+		var req struct {
+			TenantID string `json:"tenant_id"`
+			Source   string `json:"source"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "invalid JSON", http.StatusBadRequest)
+			return
+		}
+		req.TenantID = strings.TrimSpace(req.TenantID)
+		if !vendorcloud.ValidSource(req.Source) {
+			http.Error(w, "unknown source", http.StatusBadRequest)
+			return
+		}
+		if tenant, err := serverStore.GetTenant(ctx, req.TenantID); req.TenantID == "" || err != nil || tenant == nil {
+			http.Error(w, "unknown tenant", http.StatusBadRequest)
+			return
+		}
+		token, err := generateToken()
+		if err != nil {
+			http.Error(w, "failed to generate secret", http.StatusInternalServerError)
+			return
+		}
+		actorType, actorID, actorName, _ := auditActorFromPrincipal(r)
+		secret := &storage.VendorWebhookSecret{TenantID: req.TenantID, Source: req.Source, Secret: token, CreatedBy: actorName}
+		if err := serverStore.SetVendorWebhookSecret(ctx, secret); err != nil {
+			logError("Vendor webhook: failed to store secret", "tenant_id", req.TenantID, "source", req.Source, "error", err)
+			http.Error(w, "failed to store secret", http.StatusInternalServerError)
+			return
+		}
+		logAuditEntry(ctx, &storage.AuditEntry{
+			ActorType:  actorType,
+			ActorID:    actorID,
+			ActorName:  actorName,
+			TenantID:   req.TenantID,
+			Action:     "vendor_webhook.secret_generated",
+			TargetType: "vendor_webhook",
+			TargetID:   req.Source,
+			Severity:   storage.AuditSeverityWarn,
+			Details:    fmt.Sprintf("Generated %s webhook secret for tenant %s", vendorcloud.SourceName(req.Source), req.TenantID),
+			IPAddress:  extractClientIP(r),
+			UserAgent:  r.UserAgent(),
+		})
+		writeVendorWebhookResult(w, http.StatusCreated, map[string]interface{}{
+			"tenant_id": secret.TenantID,
+			"source":    secret.Source,
+			"secret":    secret.Secret,
+			"path":      vendorWebhookTenantPath(secret.Source, secret.TenantID),
+		})
+
+	case http.MethodDelete:
+		if !authorizeOrReject(w, r, authz.ActionSettingsServerWrite, authz.ResourceRef{}) {
+			return
+		}
+		tenantID := strings.TrimSpace(r.URL.Query().Get("tenant_id"))
+		source := strings.TrimSpace(r.URL.Query().Get("source"))
+		err := serverStore.DeleteVendorWebhookSecret(ctx, tenantID, source)
+		if errors.Is(err, storage.ErrVendorWebhookSecretNotFound) {
+			http.Error(w, "secret not found", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			logError("Vendor webhook: failed to delete secret", "tenant_id", tenantID, "source", source, "error", err)
+			http.Error(w, "failed to delete secret", http.StatusInternalServerError)
+			return
+		}
+		actorType, actorID, actorName, _ := auditActorFromPrincipal(r)
+		logAuditEntry(ctx, &storage.AuditEntry{
+			ActorType:  actorType,
+			ActorID:    actorID,
+			ActorName:  actorName,
+			TenantID:   tenantID,
+			Action:     "vendor_webhook.secret_deleted",
+			TargetType: "vendor_webhook",
+			TargetID:   source,
+			Details:    fmt.Sprintf("Deleted %s webhook secret for tenant %s", vendorcloud.SourceName(source), tenantID),
+			IPAddress:  extractClientIP(r),
+			UserAgent:  r.UserAgent(),
+		})
+		w.WriteHeader(http.StatusNoContent)
+
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// handleVendorReadings serves GET /api/v1/integrations/readings?serial=
+// &source=&kind=&reconcile=&delivery=&limit= with vendor readings, newest
+// first. Readings belong to the tenant whose webhook delivered them, including
+// unmatched ones.
+func handleVendorReadings(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "GET only", http.StatusMethodNotAllowed)
+		return
+	}
+	if !authorizeOrReject(w, r, authz.ActionDevicesRead, authz.ResourceRef{}) {
+		return
+	}
+	scope, ok := tenantScope(getPrincipal(r))
+	if !ok {
+		http.Error(w, "forbidden", http.StatusForbidden)
+		return
+	}
+
+	q := r.URL.Query()
+	filter := storage.VendorReadingFilter{
+		Serial:    strings.TrimSpace(q.Get("serial")),
+		Source:    strings.TrimSpace(q.Get("source")),
+		Kind:      strings.TrimSpace(q.Get("kind")),
+		Reconcile: strings.TrimSpace(q.Get("reconcile")),
+	}
+	if v := q.Get("delivery"); v != "" {
+		id, err := strconv.ParseInt(v, 10, 64)
+		if err != nil || id <= 0 {
+			http.Error(w, "invalid delivery", http.StatusBadRequest)
+			return
+		}
+		filter.DeliveryID = id
+	}
+	limit, ok := vendorListLimit(w, q.Get("limit"))
+	if !ok {
+		return
+	}
+	filter.Limit = limit
+	if scope != nil {
+		for id := range scope {
+			filter.TenantIDs = append(filter.TenantIDs, id)
+		}
+	}
+
+	readings, err := serverStore.ListVendorReadings(r.Context(), filter)
+	if err != nil {
+		logError("Vendor webhook: failed to list readings", "error", err)
+		http.Error(w, "failed to load readings", http.StatusInternalServerError)
+		return
+	}
+	if readings == nil {
+		readings = []*storage.VendorReading{}
+	}
+	writeVendorWebhookResult(w, http.StatusOK, map[string]interface{}{"readings": readings})
+}
+
+// vendorListLimit parses a limit query value, writing a 400 when invalid.
+func vendorListLimit(w http.ResponseWriter, v string) (int, bool) {
+	if v == "" {
+		return vendorDefaultLimit, true
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil || n <= 0 {
+		http.Error(w, "invalid limit", http.StatusBadRequest)
+		return 0, false
+	}
+	return min(n, vendorMaxLimit), true
+}
+
+func writeVendorWebhookResult(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
+
+// pruneVendorWebhooks drops vendor deliveries and readings older than
+// retentionDays.
+func pruneVendorWebhooks(ctx context.Context, retentionDays int) {
+	if retentionDays <= 0 {
+		return
+	}
+	removed, err := serverStore.DeleteVendorWebhookDataBefore(ctx, time.Now().AddDate(0, 0, -retentionDays))
+	if err != nil {
+		logWarn("Failed to prune vendor webhooks", "error", err)
+		return
+	}
+	if removed > 0 {
+		logInfo("Pruned old vendor webhooks", "count", removed, "retention_days", retentionDays)
+	}
+}
@@ -0,0 +1,251 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"printmaster/server/storage"
+	"printmaster/server/vendorcloud"
+)
+
+func TestVendorWebhookHandler(t *testing.T) {
+	store := SetupTestStore(t)
+	ctx := context.Background()
+	now := time.Now().UTC()
+
+	for _, tn := range []*storage.Tenant{{ID: "tenant-a", Name: "Acme"}, {ID: "tenant-b", Name: "Globex"}} {
+		if err := store.CreateTenant(ctx, tn); err != nil {
+			t.Fatalf("CreateTenant: %v", err)
+		}
+	}
+	// NOTOURS belongs to another tenant, so tenant-a deliveries must not match it.
+	for _, a := range []struct{ agent, tenant, serial string }{{"agent-a", "tenant-a", "CNB1234567"}, {"agent-b", "tenant-b", "NOTOURS"}} {
+		agent := &storage.Agent{AgentID: a.agent, Hostname: a.agent + "-pc", Token: "t-" + a.agent, TenantID: a.tenant, Status: "active", RegisteredAt: now, LastSeen: now}
+		if err := store.RegisterAgent(ctx, agent); err != nil {
+			t.Fatalf("RegisterAgent: %v", err)
+		}
+		dev := &storage.Device{AgentID: a.agent}
+		dev.Serial, dev.IP, dev.LastSeen = a.serial, "10.0.0.5", now
+		if err := store.UpsertDevice(ctx, dev); err != nil {
+			t.Fatalf("UpsertDevice: %v", err)
+		}
+	}
+	if err := store.SetVendorWebhookSecret(ctx, &storage.VendorWebhookSecret{TenantID: "tenant-a", Source: vendorcloud.SourceHPSDS, Secret: "hp-secret"}); err != nil {
+		t.Fatalf("SetVendorWebhookSecret: %v", err)
+	}
+	if err := store.SaveMetrics(ctx, &storage.MetricsSnapshot{Serial: "CNB1234567", AgentID: "agent-a", Timestamp: now.Add(-time.Hour),
+		PageCount: 5000, TonerLevels: map[string]interface{}{"Black Toner": 60}}); err != nil {
+		t.Fatalf("SaveMetrics: %v", err)
+	}
+
+	handler := newVendorWebhookHandler(VendorWebhooksConfig{Enabled: true})
+	postTo := func(path, secret, deliveryID string, payload string) (*httptest.ResponseRecorder, map[string]interface{}) {
+		t.Helper()
+		body := []byte(payload)
+		ts := strconv.FormatInt(time.Now().Unix(), 10)
+		req := httptest.NewRequest(http.MethodPost, path, bytes.NewReader(body))
+		req.Header.Set(vendorcloud.HeaderTimestamp, ts)
+		req.Header.Set(vendorcloud.HeaderSignature, vendorcloud.Sign([]byte(secret), ts, deliveryID, body))
+		if deliveryID != "" {
+			req.Header.Set(vendorcloud.HeaderDelivery, deliveryID)
+		}
+		rr := httptest.NewRecorder()
+		handler(rr, req)
+		var resp map[string]interface{}
+		_ = json.Unmarshal(rr.Body.Bytes(), &resp)
+		return rr, resp
+	}
+	post := func(source, secret, deliveryID string, payload string) (*httptest.ResponseRecorder, map[string]interface{}) {
+		t.Helper()
+		return postTo(vendorWebhookTenantPath(source, "tenant-a"), secret, deliveryID, payload)
+	}
+
+	at := now.Format(time.RFC3339)
+	payload := `[{"eventTime": "` + at + `", "device": {"serialNumber": "CNB1234567"},
+		"alert": {"code": "10.00.00", "severity": "error", "status": "active", "description": "Supply memory error"},
+		"supplies": [{"color": "black", "percentRemaining": 20}], "meters": {"totalImpressions": 5100}},
+		{"eventTime": "` + at + `", "device": {"serialNumber": "NOTOURS"}, "meters": {"totalImpressions": 10}}]`
+
+	if rr, _ := post(vendorcloud.SourceLexmarkCloud, "x", "", payload); rr.Code != http.StatusNotFound {
+		t.Errorf("unconfigured source: code=%d, want 404", rr.Code)
+	}
+	if rr, _ := postTo(vendorWebhookTenantPath(vendorcloud.SourceHPSDS, "tenant-b"), "hp-secret", "", payload); rr.Code != http.StatusNotFound {
+		t.Errorf("tenant without secret: code=%d, want 404", rr.Code)
+	}
+	if rr, _ := postTo(vendorWebhookPath+vendorcloud.SourceHPSDS, "hp-secret", "", payload); rr.Code != http.StatusNotFound {
+		t.Errorf("missing tenant: code=%d, want 404", rr.Code)
+	}
+	if rr, _ := post(vendorcloud.SourceHPSDS, "wrong", "", payload); rr.Code != http.StatusUnauthorized {
+		t.Errorf("bad signature: code=%d, want 401", rr.Code)
+	}
+	if rr, _ := post(vendorcloud.SourceHPSDS, "hp-secret", "bad-1", `{"nope": true}`); rr.Code != http.StatusBadRequest {
+		t.Errorf("unreadable payload: code=%d, want 400", rr.Code)
+	}
+
+	rr, resp := post(vendorcloud.SourceHPSDS, "hp-secret", "d-1", payload)
+	if rr.Code != http.StatusOK || resp["alerts_created"] != float64(1) {
+		t.Fatalf("delivery: code=%d body=%s", rr.Code, rr.Body.String())
+	}
+	if rr, resp := post(vendorcloud.SourceHPSDS, "hp-secret", "d-1", payload); rr.Code != http.StatusOK || resp["duplicate"] != true {
+		t.Errorf("duplicate delivery: code=%d body=%v", rr.Code, resp)
+	}
+
+	// Replaying the captured delivery under a new delivery ID breaks the signature
+	ts := strconv.FormatInt(time.Now().Unix(), 10)
+	replay := httptest.NewRequest(http.MethodPost, vendorWebhookTenantPath(vendorcloud.SourceHPSDS, "tenant-a"), bytes.NewReader([]byte(payload)))
+	replay.Header.Set(vendorcloud.HeaderTimestamp, ts)
+	replay.Header.Set(vendorcloud.HeaderSignature, vendorcloud.Sign([]byte("hp-secret"), ts, "d-1", []byte(payload)))
+	replay.Header.Set(vendorcloud.HeaderDelivery, "d-1-replayed")
+	rrReplay := httptest.NewRecorder()
+	handler(rrReplay, replay)
+	if rrReplay.Code != http.StatusUnauthorized {
+		t.Errorf("replay with new delivery id: code=%d, want 401", rrReplay.Code)
+	}
+
+	readings, err := store.ListVendorReadings(ctx, storage.VendorReadingFilter{DeliveryID: int64(resp["delivery"].(map[string]interface{})["id"].(float64))})
+	if err != nil || len(readings) != 4 {
+		t.Fatalf("readings = %d, %v", len(readings), err)
+	}
+	byMetric := map[string]*storage.VendorReading{}
+	for _, r := range readings {
+		byMetric[r.Serial+"/"+r.Metric] = r
+	}
+	if r := byMetric["CNB1234567/black"]; r == nil || r.Reconcile != storage.VendorReconcileConflict || r.TenantID != "tenant-a" {
+		t.Errorf("supply reading = %+v", r)
+	}
+	if r := byMetric["CNB1234567/page_count"]; r == nil || r.Reconcile != storage.VendorReconcileMatch || r.AgentValue == nil || *r.AgentValue != 5000 {
+		t.Errorf("counter reading = %+v", r)
+	}
+	if r := byMetric["NOTOURS/page_count"]; r == nil || r.Reconcile != storage.VendorReconcileUnmatched || r.TenantID != "tenant-a" || r.AgentID != "" {
+		t.Errorf("unmatched reading = %+v", r)
+	}
+	alertReading := byMetric["CNB1234567/10.00.00"]
+	if alertReading == nil || alertReading.AlertID == 0 {
+		t.Fatalf("alert reading = %+v", alertReading)
+	}
+
+	active, err := store.ListActiveAlerts(ctx, storage.AlertFilters{Type: storage.AlertTypeDeviceError, TenantID: "tenant-a"})
+	if err != nil || len(active) != 1 || active[0].Severity != storage.AlertSeverityCritical || active[0].DeviceSerial != "CNB1234567" {
+		t.Fatalf("active alerts = %+v, %v", active, err)
+	}
+
+	cleared := `{"device": {"serialNumber": "CNB1234567"}, "alert": {"code": "10.00.00", "status": "cleared"}}`
+	if rr, resp := post(vendorcloud.SourceHPSDS, "hp-secret", "d-2", cleared); rr.Code != http.StatusOK || resp["alerts_resolved"] != float64(1) {
+		t.Errorf("cleared alert: code=%d body=%v", rr.Code, resp)
+	}
+	if active, _ := store.ListActiveAlerts(ctx, storage.AlertFilters{Type: storage.AlertTypeDeviceError}); len(active) != 0 {
+		t.Errorf("expected vendor alert to be resolved, got %+v", active)
+	}
+
+	fetch := func(user *storage.User, query string) ([]*storage.VendorReading, int) {
+		t.Helper()
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/integrations/readings?"+query, nil)
+		rr := httptest.NewRecorder()
+		handleVendorReadings(rr, InjectTestUser(req, user))
+		var resp struct {
+			Readings []*storage.VendorReading `json:"readings"`
+		}
+		if rr.Code == http.StatusOK {
+			if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil {
+				t.Fatalf("decode: %v", err)
+			}
+		}
+		return resp.Readings, rr.Code
+	}
+	if got, code := fetch(NewTestAdminUser(), "reconcile=unmatched"); code != http.StatusOK || len(got) != 1 {
+		t.Errorf("admin unmatched: code=%d readings=%d", code, len(got))
+	}
+	if got, code := fetch(NewTestUser(storage.RoleViewer, "tenant-a"), "serial=CNB1234567"); code != http.StatusOK || len(got) != 4 {
+		t.Errorf("tenant-a readings: code=%d readings=%d", code, len(got))
+	}
+	if got, code := fetch(NewTestUser(storage.RoleViewer, "tenant-b"), ""); code != http.StatusOK || len(got) != 0 {
+		t.Errorf("tenant-b readings: code=%d readings=%d", code, len(got))
+	}
+	if got, code := fetch(NewTestUser(storage.RoleViewer, "tenant-a"), "reconcile=unmatched"); code != http.StatusOK || len(got) != 1 {
+		t.Errorf("tenant-a unmatched: code=%d readings=%d", code, len(got))
+	}
+	if got, code := fetch(NewTestUser(storage.RoleViewer, "tenant-b"), "reconcile=unmatched"); code != http.StatusOK || len(got) != 0 {
+		t.Errorf("tenant-b unmatched: code=%d readings=%d", code, len(got))
+	}
+
+	inbox := newVendorWebhookInboxHandler(VendorWebhooksConfig{Enabled: true})
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/integrations/webhooks", nil)
+	irr := httptest.NewRecorder()
+	inbox(irr, InjectTestUser(req, NewTestAdminUser()))
+	var inboxResp struct {
+		Sources []struct {
+			Source  string   `json:"source"`
+			Enabled bool     `json:"enabled"`
+			Tenants []string `json:"tenants"`
+		} `json:"sources"`
+		Deliveries []*storage.VendorWebhookDelivery `json:"deliveries"`
+	}
+	if err := json.Unmarshal(irr.Body.Bytes(), &inboxResp); err != nil || irr.Code != http.StatusOK {
+		t.Fatalf("inbox: code=%d err=%v", irr.Code, err)
+	}
+	if len(inboxResp.Deliveries) != 3 || len(inboxResp.Sources) != 2 || !inboxResp.Sources[0].Enabled ||
+		len(inboxResp.Sources[0].Tenants) != 1 || inboxResp.Sources[0].Tenants[0] != "tenant-a" || len(inboxResp.Sources[1].Tenants) != 0 {
+		t.Errorf("inbox = %+v", inboxResp)
+	}
+}
+
+func TestVendorWebhookSecretsHandler(t *testing.T) {
+	store := SetupTestStore(t)
+	ctx := context.Background()
+	if err := store.CreateTenant(ctx, &storage.Tenant{ID: "tenant-a", Name: "Acme"}); err != nil {
+		t.Fatalf("CreateTenant: %v", err)
+	}
+
+	call := func(user *storage.User, method, target, body string) *httptest.ResponseRecorder {
+		t.Helper()
+		req := httptest.NewRequest(method, target, bytes.NewReader([]byte(body)))
+		rr := httptest.NewRecorder()
+		handleVendorWebhookSecrets(rr, InjectTestUser(req, user))
+		return rr
+	}
+	const base = "/api/v1/integrations/webhook-secrets"
+
+	if rr := call(NewTestUser(storage.RoleViewer, "tenant-a"), http.MethodPost, base, `{"tenant_id":"tenant-a","source":"hp_sds"}`); rr.Code != http.StatusForbidden {
+		t.Errorf("viewer create: code=%d, want 403", rr.Code)
+	}
+	if rr := call(NewTestAdminUser(), http.MethodPost, base, `{"tenant_id":"nope","source":"hp_sds"}`); rr.Code != http.StatusBadRequest {
+		t.Errorf("unknown tenant: code=%d, want 400", rr.Code)
+	}
+	if rr := call(NewTestAdminUser(), http.MethodPost, base, `{"tenant_id":"tenant-a","source":"nope"}`); rr.Code != http.StatusBadRequest {
+		t.Errorf("unknown source: code=%d, want 400", rr.Code)
+	}
+
+	rr := call(NewTestAdminUser(), http.MethodPost, base, `{"tenant_id":"tenant-a","source":"hp_sds"}`)
+	var created struct {
+		Secret string `json:"secret"`
+		Path   string `json:"path"`
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &created); err != nil || rr.Code != http.StatusCreated || created.Secret == "" {
+		t.Fatalf("create: code=%d body=%s", rr.Code, rr.Body.String())
+	}
+	if created.Path != vendorWebhookTenantPath(vendorcloud.SourceHPSDS, "tenant-a") {
+		t.Errorf("path = %q", created.Path)
+	}
+	stored, err := store.GetVendorWebhookSecret(ctx, "tenant-a", vendorcloud.SourceHPSDS)
+	if err != nil || stored == nil || stored.Secret != created.Secret {
+		t.Fatalf("stored secret = %+v, %v", stored, err)
+	}
+
+	rr = call(NewTestAdminUser(), http.MethodGet, base, "")
+	if rr.Code != http.StatusOK || bytes.Contains(rr.Body.Bytes(), []byte(created.Secret)) {
+		t.Errorf("list: code=%d body=%s", rr.Code, rr.Body.String())
+	}
+
+	if rr := call(NewTestAdminUser(), http.MethodDelete, base+"?tenant_id=tenant-a&source=hp_sds", ""); rr.Code != http.StatusNoContent {
+		t.Errorf("delete: code=%d", rr.Code)
+	}
+	if rr := call(NewTestAdminUser(), http.MethodDelete, base+"?tenant_id=tenant-a&source=hp_sds", ""); rr.Code != http.StatusNotFound {
+		t.Errorf("delete missing: code=%d, want 404", rr.Code)
+	}
+}
@@ -0,0 +1,195 @@
+package vendorcloud
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// decodeEntries accepts a single notification, an array of them or an
+// {"events": [...]} envelope.
+func decodeEntries[T any](body []byte) ([]T, error) {
+	body = bytes.TrimSpace(body)
+	if len(body) == 0 {
+		return nil, fmt.Errorf("empty payload")
+	}
+	if body[0] == '[' {
+		var entries []T
+		if err := json.Unmarshal(body, &entries); err != nil {
+			return nil, fmt.Errorf("invalid JSON: %w", err)
+		}
+		return entries, nil
+	}
+	var envelope struct {
+		Events []T `json:"events"`
+	}
+	if err := json.Unmarshal(body, &envelope); err != nil {
+		return nil, fmt.Errorf("invalid JSON: %w", err)
+	}
+	if len(envelope.Events) > 0 {
+		return envelope.Events, nil
+	}
+	var entry T
+	if err := json.Unmarshal(body, &entry); err != nil {
+		return nil, fmt.Errorf("invalid JSON: %w", err)
+	}
+	return []T{entry}, nil
+}
+
+// alertState maps vendor alert statuses onto active and cleared.
+func alertState(status string) string {
+	switch strings.ToLower(strings.TrimSpace(status)) {
+	case "cleared", "closed", "resolved", "inactive":
+		return AlertCleared
+	}
+	return AlertActive
+}
+
+// counterEvents turns the counters present in a notification into events.
+func counterEvents(serial string, at time.Time, total, mono, color *int) []Event {
+	var events []Event
+	for _, c := range []struct {
+		name  string
+		value *int
+	}{{CounterTotal, total}, {CounterMono, mono}, {CounterColor, color}} {
+		if c.value != nil && *c.value >= 0 {
+			events = append(events, Event{Serial: serial, Kind: KindCounter, Metric: c.name, Value: *c.value, ObservedAt: at})
+		}
+	}
+	return events
+}
+
+// hpSDSNotification is an HP Smart Device Services device notification.
+// Alert, supply and meter sections are read whenever present.
+type hpSDSNotification struct {
+	EventTime string `json:"eventTime"`
+	Device    struct {
+		SerialNumber string `json:"serialNumber"`
+	} `json:"device"`
+	Alert *struct {
+		Code        string `json:"code"`
+		Severity    string `json:"severity"`
+		Status      string `json:"status"`
+		Description string `json:"description"`
+	} `json:"alert"`
+	Supplies []struct {
+		Color            string `json:"color"`
+		Type             string `json:"type"`
+		PercentRemaining *int   `json:"percentRemaining"`
+	} `json:"supplies"`
+	Meters *struct {
+		TotalImpressions *int `json:"totalImpressions"`
+		MonoImpressions  *int `json:"monoImpressions"`
+		ColorImpressions *int `json:"colorImpressions"`
+	} `json:"meters"`
+}
+
+func parseHPSDS(body []byte) ([]Event, error) {
+	entries, err := decodeEntries[hpSDSNotification](body)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now().UTC()
+	var events []Event
+	for _, n := range entries {
+		serial := strings.TrimSpace(n.Device.SerialNumber)
+		if serial == "" {
+			continue
+		}
+		at := parseTime(n.EventTime, now)
+		if a := n.Alert; a != nil && (a.Code != "" || a.Description != "") {
+			events = append(events, Event{
+				Serial:     serial,
+				Kind:       KindAlert,
+				Metric:     strings.TrimSpace(a.Code),
+				Severity:   normalizeSeverity(a.Severity),
+				State:      alertState(a.Status),
+				Message:    strings.TrimSpace(a.Description),
+				ObservedAt: at,
+			})
+		}
+		for _, s := range n.Supplies {
+			name := supplyColor(s.Color)
+			if name == "" {
+				name = supplyColor(s.Type)
+			}
+			if name == "" || s.PercentRemaining == nil {
+				continue
+			}
+			events = append(events, Event{Serial: serial, Kind: KindSupply, Metric: name, Value: *s.PercentRemaining, ObservedAt: at})
+		}
+		if m := n.Meters; m != nil {
+			events = append(events, counterEvents(serial, at, m.TotalImpressions, m.MonoImpressions, m.ColorImpressions)...)
+		}
+	}
+	return events, nil
+}
+
+// lexmarkNotification is a Lexmark Cloud Services printer notification; type
+// is ALERT, SUPPLY or COUNTER and selects which data fields are read.
+type lexmarkNotification struct {
+	Type      string `json:"type"`
+	CreatedAt string `json:"createdAt"`
+	Printer   struct {
+		SerialNumber string `json:"serialNumber"`
+	} `json:"printer"`
+	Data struct {
+		AlertCode string `json:"alertCode"`
+		Severity  string `json:"severity"`
+		Status    string `json:"status"`
+		Message   string `json:"message"`
+		Supplies  []struct {
+			Type             string `json:"type"`
+			Color            string `json:"color"`
+			PercentRemaining *int   `json:"percentRemaining"`
+		} `json:"supplies"`
+		LifetimeCount *int `json:"lifetimeCount"`
+		MonoCount     *int `json:"monoCount"`
+		ColorCount    *int `json:"colorCount"`
+	} `json:"data"`
+}
+
+func parseLexmarkCloud(body []byte) ([]Event, error) {
+	entries, err := decodeEntries[lexmarkNotification](body)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now().UTC()
+	var events []Event
+	for _, n := range entries {
+		serial := strings.TrimSpace(n.Printer.SerialNumber)
+		if serial == "" {
+			continue
+		}
+		at := parseTime(n.CreatedAt, now)
+		d := n.Data
+		switch strings.ToUpper(strings.TrimSpace(n.Type)) {
+		case "ALERT":
+			events = append(events, Event{
+				Serial:     serial,
+				Kind:       KindAlert,
+				Metric:     strings.TrimSpace(d.AlertCode),
+				Severity:   normalizeSeverity(d.Severity),
+				State:      alertState(d.Status),
+				Message:    strings.TrimSpace(d.Message),
+				ObservedAt: at,
+			})
+		case "SUPPLY":
+			for _, s := range d.Supplies {
+				name := supplyColor(s.Color)
+				if name == "" {
+					name = supplyColor(s.Type)
+				}
+				if name == "" || s.PercentRemaining == nil {
+					continue
+				}
+				events = append(events, Event{Serial: serial, Kind: KindSupply, Metric: name, Value: *s.PercentRemaining, ObservedAt: at})
+			}
+		case "COUNTER":
+			events = append(events, counterEvents(serial, at, d.LifetimeCount, d.MonoCount, d.ColorCount)...)
+		}
+	}
+	return events, nil
+}
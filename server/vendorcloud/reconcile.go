@@ -0,0 +1,111 @@
+package vendorcloud
+
+import (
+	"sort"
+	"strings"
+	"time"
+
+	"printmaster/server/storage"
+)
+
+const (
+	// StaleAfter is how much newer a vendor reading must be than the agent's
+	// latest one before the agent data counts as stale.
+	StaleAfter = 24 * time.Hour
+	// SupplyTolerance is how many percentage points a vendor supply level
+	// may differ from the agent's before the two conflict.
+	SupplyTolerance = 10
+)
+
+// Reconciliation compares one vendor reading with the agent's latest reading.
+type Reconciliation struct {
+	Status          string
+	AgentValue      *int
+	AgentObservedAt *time.Time
+}
+
+// Reconcile compares a counter or supply event with latest, the agent's most
+// recent metrics for the device (nil when there are none). Counters only
+// grow, so a vendor count below an older agent count (or above a newer one)
+// is a conflict; supply levels conflict when they differ by more than
+// SupplyTolerance points and the readings are close in time.
+func Reconcile(e Event, latest *storage.MetricsSnapshot) Reconciliation {
+	if latest == nil {
+		return Reconciliation{Status: storage.VendorReconcileVendorOnly}
+	}
+	agentValue, ok := agentValue(e, latest)
+	if !ok {
+		return Reconciliation{Status: storage.VendorReconcileVendorOnly}
+	}
+	at := latest.Timestamp
+	res := Reconciliation{AgentValue: &agentValue, AgentObservedAt: &at}
+	vendorNewer := e.ObservedAt.After(at)
+	stale := e.ObservedAt.Sub(at) > StaleAfter
+
+	switch e.Kind {
+	case KindCounter:
+		switch {
+		case vendorNewer && e.Value < agentValue, !vendorNewer && e.Value > agentValue:
+			res.Status = storage.VendorReconcileConflict
+		case stale:
+			res.Status = storage.VendorReconcileAgentStale
+		default:
+			res.Status = storage.VendorReconcileMatch
+		}
+	case KindSupply:
+		diff := e.Value - agentValue
+		if diff < 0 {
+			diff = -diff
+		}
+		switch {
+		case diff <= SupplyTolerance:
+			res.Status = storage.VendorReconcileMatch
+		case stale:
+			res.Status = storage.VendorReconcileAgentStale
+		default:
+			res.Status = storage.VendorReconcileConflict
+		}
+	default:
+		return Reconciliation{Status: storage.VendorReconcileVendorOnly}
+	}
+	return res
+}
+
+// agentValue finds the agent's value for the event's counter or supply.
+// Supplies match the first toner level (by name) that contains the color.
+func agentValue(e Event, m *storage.MetricsSnapshot) (int, bool) {
+	switch e.Kind {
+	case KindCounter:
+		var v int
+		switch e.Metric {
+		case CounterTotal:
+			v = m.PageCount
+		case CounterMono:
+			v = m.MonoPages
+		case CounterColor:
+			v = m.ColorPages
+		}
+		// Zero means the agent does not report this counter
+		return v, v > 0
+	case KindSupply:
+		names := make([]string, 0, len(m.TonerLevels))
+		for name := range m.TonerLevels {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			if !strings.Contains(strings.ToLower(name), e.Metric) {
+				continue
+			}
+			switch v := m.TonerLevels[name].(type) {
+			case float64:
+				return int(v), v >= 0
+			case int:
+				return v, v >= 0
+			case int64:
+				return int(v), v >= 0
+			}
+		}
+	}
+	return 0, false
+}
@@ -0,0 +1,156 @@
+package vendorcloud
+
+import (
+	"errors"
+	"strconv"
+	"testing"
+	"time"
+
+	"printmaster/server/storage"
+)
+
+func TestSignVerify(t *testing.T) {
+	t.Parallel()
+	secret := []byte("s3cret")
+	body := []byte(`{"device":{"serialNumber":"CNB1"}}`)
+	now := time.Unix(1_700_000_000, 0)
+	ts := strconv.FormatInt(now.Unix(), 10)
+	sig := Sign(secret, ts, "d-1", body)
+
+	if err := Verify(secret, ts, "d-1", sig, body, now.Add(time.Minute), 5*time.Minute); err != nil {
+		t.Fatalf("Verify valid signature: %v", err)
+	}
+	cases := []struct {
+		name      string
+		secret    []byte
+		ts, sig   string
+		delivery  string
+		body      []byte
+		now       time.Time
+		wantError bool
+	}{
+		{"wrong secret", []byte("other"), ts, sig, "d-1", body, now, true},
+		{"tampered body", secret, ts, sig, "d-1", []byte(`{}`), now, true},
+		{"missing signature", secret, ts, "", "d-1", body, now, true},
+		{"bad timestamp", secret, "yesterday", sig, "d-1", body, now, true},
+		{"outside skew", secret, ts, sig, "d-1", body, now.Add(10 * time.Minute), true},
+		{"changed delivery id", secret, ts, sig, "d-2", body, now, true},
+		{"clock behind", secret, ts, sig, "d-1", body, now.Add(-4 * time.Minute), false},
+	}
+	for _, tc := range cases {
+		err := Verify(tc.secret, tc.ts, tc.delivery, tc.sig, tc.body, tc.now, 5*time.Minute)
+		if tc.wantError && !errors.Is(err, ErrBadSignature) {
+			t.Errorf("%s: err = %v, want ErrBadSignature", tc.name, err)
+		}
+		if !tc.wantError && err != nil {
+			t.Errorf("%s: unexpected error %v", tc.name, err)
+		}
+	}
+}
+
+func TestParseHPSDS(t *testing.T) {
+	t.Parallel()
+	body := []byte(`{"events": [
+		{"eventTime": "2026-03-01T10:00:00Z", "device": {"serialNumber": "CNB1"},
+		 "alert": {"code": "10.00.00", "severity": "ERROR", "status": "ACTIVE", "description": "Supply memory error"},
+		 "supplies": [{"color": "BLACK", "percentRemaining": 42}, {"type": "Cyan", "percentRemaining": 7}, {"color": "Magenta"}],
+		 "meters": {"totalImpressions": 12000, "colorImpressions": 3000}},
+		{"device": {"serialNumber": ""}, "meters": {"totalImpressions": 1}}
+	]}`)
+	events, err := Parse(SourceHPSDS, body)
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	if len(events) != 5 {
+		t.Fatalf("got %d events, want 5: %+v", len(events), events)
+	}
+	alert := events[0]
+	if alert.Kind != KindAlert || alert.Metric != "10.00.00" || alert.Severity != "critical" || alert.State != AlertActive ||
+		alert.Serial != "CNB1" || !alert.ObservedAt.Equal(time.Date(2026, 3, 1, 10, 0, 0, 0, time.UTC)) {
+		t.Errorf("alert = %+v", alert)
+	}
+	if events[1].Kind != KindSupply || events[1].Metric != "black" || events[1].Value != 42 || events[2].Metric != "cyan" {
+		t.Errorf("supplies = %+v %+v", events[1], events[2])
+	}
+	if events[3].Metric != CounterTotal || events[3].Value != 12000 || events[4].Metric != CounterColor {
+		t.Errorf("counters = %+v %+v", events[3], events[4])
+	}
+}
+
+func TestParseLexmarkCloud(t *testing.T) {
+	t.Parallel()
+	body := []byte(`[
+		{"type": "ALERT", "createdAt": "2026-03-01T10:00:00Z", "printer": {"serialNumber": "LX1"},
+		 "data": {"alertCode": "31.35", "severity": "low", "status": "cleared", "message": "Toner low"}},
+		{"type": "SUPPLY", "printer": {"serialNumber": "LX1"}, "data": {"supplies": [{"type": "TONER", "color": "Black", "percentRemaining": 15}]}},
+		{"type": "COUNTER", "printer": {"serialNumber": "LX1"}, "data": {"lifetimeCount": 500, "monoCount": 500}}
+	]`)
+	events, err := Parse(SourceLexmarkCloud, body)
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	if len(events) != 4 {
+		t.Fatalf("got %d events, want 4: %+v", len(events), events)
+	}
+	if events[0].State != AlertCleared || events[0].Severity != "info" || events[0].Metric != "31.35" {
+		t.Errorf("alert = %+v", events[0])
+	}
+	if events[1].Metric != "black" || events[1].Value != 15 {
+		t.Errorf("supply = %+v", events[1])
+	}
+	if events[2].Metric != CounterTotal || events[3].Metric != CounterMono {
+		t.Errorf("counters = %+v %+v", events[2], events[3])
+	}
+
+	if _, err := Parse(SourceLexmarkCloud, []byte(`{"type": "UNKNOWN", "printer": {"serialNumber": "LX1"}}`)); err == nil {
+		t.Error("expected error for payload without events")
+	}
+	if _, err := Parse(SourceLexmarkCloud, []byte(`not json`)); err == nil {
+		t.Error("expected error for invalid JSON")
+	}
+	if _, err := Parse("xerox", []byte(`{}`)); !errors.Is(err, ErrUnknownSource) {
+		t.Errorf("unknown source err = %v", err)
+	}
+}
+
+func TestReconcile(t *testing.T) {
+	t.Parallel()
+	agentAt := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	latest := &storage.MetricsSnapshot{
+		Timestamp:   agentAt,
+		PageCount:   1000,
+		TonerLevels: map[string]interface{}{"Black Toner": float64(50), "Cyan Toner": float64(80)},
+	}
+	counter := func(v int, at time.Time) Event {
+		return Event{Kind: KindCounter, Metric: CounterTotal, Value: v, ObservedAt: at}
+	}
+	supply := func(color string, v int, at time.Time) Event {
+		return Event{Kind: KindSupply, Metric: color, Value: v, ObservedAt: at}
+	}
+	cases := []struct {
+		name   string
+		event  Event
+		latest *storage.MetricsSnapshot
+		want   string
+	}{
+		{"counter grew", counter(1100, agentAt.Add(time.Hour)), latest, storage.VendorReconcileMatch},
+		{"counter went back", counter(900, agentAt.Add(time.Hour)), latest, storage.VendorReconcileConflict},
+		{"older vendor count above agent", counter(1100, agentAt.Add(-time.Hour)), latest, storage.VendorReconcileConflict},
+		{"agent stale", counter(1500, agentAt.Add(48*time.Hour)), latest, storage.VendorReconcileAgentStale},
+		{"counter not reported", Event{Kind: KindCounter, Metric: CounterColor, Value: 5, ObservedAt: agentAt}, latest, storage.VendorReconcileVendorOnly},
+		{"supply within tolerance", supply("black", 45, agentAt), latest, storage.VendorReconcileMatch},
+		{"supply differs", supply("black", 10, agentAt.Add(time.Hour)), latest, storage.VendorReconcileConflict},
+		{"supply differs, agent stale", supply("cyan", 20, agentAt.Add(72*time.Hour)), latest, storage.VendorReconcileAgentStale},
+		{"unknown supply", supply("yellow", 20, agentAt), latest, storage.VendorReconcileVendorOnly},
+		{"no agent metrics", counter(10, agentAt), nil, storage.VendorReconcileVendorOnly},
+	}
+	for _, tc := range cases {
+		got := Reconcile(tc.event, tc.latest)
+		if got.Status != tc.want {
+			t.Errorf("%s: status = %q, want %q", tc.name, got.Status, tc.want)
+		}
+		if got.Status != storage.VendorReconcileVendorOnly && (got.AgentValue == nil || got.AgentObservedAt == nil) {
+			t.Errorf("%s: expected agent value, got %+v", tc.name, got)
+		}
+	}
+}
@@ -1,6 +1,6 @@
 // PrintMaster Server - Web UI JavaScript
 
-const DEFAULT_ROLE_PRIORITY = { admin: 3, operator: 2, viewer: 1 };
+const DEFAULT_ROLE_PRIORITY = { admin: 4, tenant_admin: 3, operator: 2, auditor: 1, viewer: 1 };
 const BASE_TAB_LABELS = {
     dashboard: 'Dashboard',
     agents: 'Agents',
@@ -19,8 +19,9 @@ const TAB_DEFINITIONS = {
     },
     admin: {
         label: 'Admin',
-        // Allow operators+ to see Admin tab - specific subtabs are gated by applyAdminSubtabVisibility()
-        minRole: 'operator',
+        // Shown to anyone with at least one admin sub-view (operators+, auditors) -
+        // specific subtabs are gated by applyAdminSubtabVisibility()
+        isVisible: () => getAccessibleAdminViews().length > 0,
         templateId: 'tab-template-admin',
         onMount: () => initAdminTab()
     }
@@ -432,7 +433,12 @@ const SERVER_SETTINGS_SCHEMA = [
             { key: 'rate_limit_enabled', label: 'Rate Limiting Enabled', type: 'checkbox', helper: 'Reject login attempts after repeated failures.', configKey: 'security.rate_limit_enabled' },
             { key: 'rate_limit_max_attempts', label: 'Max Attempts', type: 'number', min: 1, helper: 'Failed logins allowed before triggering a block.', configKey: 'security.rate_limit_max_attempts' },
             { key: 'rate_limit_block_minutes', label: 'Block Duration (minutes)', type: 'number', min: 1, helper: 'How long to block an IP/user after exceeding attempts.', configKey: 'security.rate_limit_block_minutes' },
-            { key: 'rate_limit_window_minutes', label: 'Window (minutes)', type: 'number', min: 1, helper: 'Rolling window for counting failed attempts.', configKey: 'security.rate_limit_window_minutes' }
+            { key: 'rate_limit_window_minutes', label: 'Window (minutes)', type: 'number', min: 1, helper: 'Rolling window for counting failed attempts.', configKey: 'security.rate_limit_window_minutes' },
+            { key: 'session_lifetime_hours', label: 'Session Lifetime (hours)', type: 'number', min: 1, helper: 'Users log in again after this long. Applies to new sessions.', configKey: 'security.session_lifetime_hours' },
+            { key: 'session_idle_minutes', label: 'Idle Timeout (minutes)', type: 'number', min: 0, helper: 'End sessions with no activity for this long. 0 disables the idle timeout.', configKey: 'security.session_idle_minutes' },
+            { key: 'agent_token_lifetime_days', label: 'Agent Token Lifetime (days)', type: 'number', min: 1, helper: 'Agents are issued a new token during a heartbeat before this runs out.', configKey: 'security.agent_token_lifetime_days' },
+            { key: 'require_approvals', label: 'Require Second-Admin Approval', type: 'checkbox', helper: 'Hold agent deletion, forced reinstalls and agent database clears until another admin approves them.', configKey: 'security.require_approvals' },
+            { key: 'approval_expiry_hours', label: 'Approval Expiry (hours)', type: 'number', min: 1, helper: 'Pending approvals nobody acts on expire after this long.', configKey: 'security.approval_expiry_hours' }
         ]
     },
     {
@@ -607,7 +613,12 @@ function getAgentDisplayName(agent, fallbackId) {
 function buildDynamicTabs() {
     Object.entries(TAB_DEFINITIONS).forEach(([tabId, config]) => {
         const requiredAction = config && config.requiredAction;
-        const canShow = requiredAction ? userCan(requiredAction) : userHasRole((config && config.minRole) || 'viewer');
+        let canShow;
+        if (config && typeof config.isVisible === 'function') {
+            canShow = config.isVisible();
+        } else {
+            canShow = requiredAction ? userCan(requiredAction) : userHasRole((config && config.minRole) || 'viewer');
+        }
         if (canShow) {
             mountTab(tabId, config);
         }
@@ -725,6 +736,7 @@ document.addEventListener('DOMContentLoaded', function () {
 
         // Initialize theme toggle
         initThemeToggle();
+        initGlobalSearch();
 
         // Initialize tabs (after dynamic tabs injected)
         initTabs();
@@ -849,11 +861,23 @@ async function logout() {
 function updateAuthUI() {
     const btn = document.getElementById('logout_btn');
     if (!btn) return;
+    const sessionsBtn = document.getElementById('my_sessions_btn');
+    const notificationsBtn = document.getElementById('my_notifications_btn');
     if (currentUser) {
         btn.style.display = 'inline-block';
         btn.onclick = logout;
+        if (sessionsBtn) {
+            sessionsBtn.style.display = 'inline-block';
+            sessionsBtn.onclick = loadMySessions;
+        }
+        if (notificationsBtn) {
+            notificationsBtn.style.display = 'inline-block';
+            notificationsBtn.onclick = showMyNotificationsModal;
+        }
     } else {
         btn.style.display = 'none';
+        if (sessionsBtn) sessionsBtn.style.display = 'none';
+        if (notificationsBtn) notificationsBtn.style.display = 'none';
     }
 }
 
@@ -1451,16 +1475,21 @@ function getValidAdminViewForUser(preferredView) {
 /**
  * Get list of admin sub-views the current user can access.
  * - Global admins: All views
- * - Tenant-scoped users (any role): Only fleet and alertsconfig for their tenants
- * - Global operators: Same as tenant-scoped (fleet/alertsconfig) since they can't manage users/tenants anyway
+ * - Tenant admins: Users, tenants, fleet, alerts config and audit for their tenants
+ * - Operators: Only fleet and alertsconfig for their tenants
+ * - Auditors: Only the audit log for their tenants
  */
 function getAccessibleAdminViews() {
     if (isGlobalAdmin()) {
         return VALID_ADMIN_VIEWS;
     }
-    // Non-admin users (including tenant-scoped) can only access fleet and alertsconfig
-    // Even global operators can't manage users, tenants, access, server, or audit
-    return ['fleet', 'alertsconfig'];
+    // Access, server settings and SSO stay with global admins
+    const views = [];
+    if (userCan('users.read')) views.push('users');
+    if (userCan('tenants.read')) views.push('tenants');
+    if (userHasRole('operator')) views.push('fleet', 'alertsconfig');
+    if (userCan('audit.logs.read')) views.push('audit');
+    return views;
 }
 
 /**
@@ -1523,6 +1552,7 @@ function ensureAdminViewReady(view) {
             initSSOAdmin();
             refreshSSOProviders();
             loadSessions();
+            loadActionApprovals();
             break;
         case 'tenants':
             initTenantsUI();
@@ -1531,6 +1561,7 @@ function ensureAdminViewReady(view) {
         case 'fleet':
             initSettingsUI();
             loadAgentUpdatePolicyForUpdatesTab();
+            loadAgentUpdateFailures();
             loadReleaseArtifacts();
             break;
         case 'server':
@@ -1593,25 +1624,107 @@ async function loadSessions() {
 function renderSessions(sessions) {
     const container = document.getElementById('sessions_list');
     if (!container) return;
+    updateSessionsSelection();
     if (!Array.isArray(sessions) || sessions.length === 0) {
         container.innerHTML = '<div class="muted-text">No active sessions.</div>';
         return;
     }
+    const currentTokenHash = currentUser?.session_token_hash || '';
     const rows = sessions.map(s => {
         const created = s.created_at ? new Date(s.created_at).toLocaleString() : 'N/A';
+        const lastSeen = s.last_seen_at ? formatRelativeTime(new Date(s.last_seen_at)) : 'N/A';
         const expires = s.expires_at ? new Date(s.expires_at).toLocaleString() : 'N/A';
         const username = escapeHtml(s.username || `User #${s.user_id}`);
+        const key = escapeHtml(s.token || '');
+        const current = s.token === currentTokenHash ? ' <span class="session-badge current">Current</span>' : '';
         return `<tr>
-            <td>${username}</td>
+            <td><input type="checkbox" class="session-select" data-session-hash="${key}"></td>
+            <td>${username}${current}</td>
+            <td title="${escapeHtml(s.user_agent || '')}">${escapeHtml(describeUserAgent(s.user_agent) || '—')}</td>
+            <td>${escapeHtml(s.ip_address || '—')}</td>
             <td>${created}</td>
+            <td>${lastSeen}</td>
             <td>${expires}</td>
-            <td><button class="ghost-btn danger-btn" data-session-hash="${escapeHtml(s.token_hash || '')}" onclick="revokeSession(this)">Revoke</button></td>
+            <td style="white-space:nowrap;">
+                <button class="ghost-btn danger-btn" data-session-hash="${key}" onclick="revokeSession(this)">Revoke</button>
+                <button class="ghost-btn" data-user-id="${escapeHtml(String(s.user_id))}" data-username="${username}" onclick="forceLogoutUser(this)" title="End every session of this user">Log Out User</button>
+            </td>
         </tr>`;
     }).join('');
     container.innerHTML = `<table class="data-table">
-        <thead><tr><th>User</th><th>Created</th><th>Expires</th><th>Action</th></tr></thead>
+        <thead><tr><th><input type="checkbox" id="sessions_select_all" title="Select all"></th><th>User</th><th>Client</th><th>IP Address</th><th>Created</th><th>Last Active</th><th>Expires</th><th>Action</th></tr></thead>
         <tbody>${rows}</tbody>
     </table>`;
+    const selectAll = document.getElementById('sessions_select_all');
+    if (selectAll) {
+        selectAll.addEventListener('change', () => {
+            container.querySelectorAll('.session-select').forEach(cb => { cb.checked = selectAll.checked; });
+            updateSessionsSelection();
+        });
+    }
+    container.querySelectorAll('.session-select').forEach(cb => cb.addEventListener('change', updateSessionsSelection));
+}
+
+function selectedSessionHashes() {
+    return Array.from(document.querySelectorAll('#sessions_list .session-select:checked'))
+        .map(cb => cb.dataset.sessionHash)
+        .filter(Boolean);
+}
+
+function updateSessionsSelection() {
+    const btn = document.getElementById('sessions_revoke_selected_btn');
+    if (!btn) return;
+    const count = selectedSessionHashes().length;
+    btn.disabled = count === 0;
+    btn.textContent = count > 0 ? `Revoke Selected (${count})` : 'Revoke Selected';
+}
+
+async function postSessionRevoke(body) {
+    const r = await fetch('/api/v1/sessions/revoke', {
+        method: 'POST',
+        headers: { 'Content-Type': 'application/json' },
+        body: JSON.stringify(body)
+    });
+    if (!r.ok) throw new Error(await r.text());
+    return r.json();
+}
+
+async function revokeSelectedSessions() {
+    const keys = selectedSessionHashes();
+    if (keys.length === 0) return;
+    const loggingOut = keys.includes(currentUser?.session_token_hash || '');
+    const msg = `Revoke ${keys.length} session${keys.length !== 1 ? 's' : ''}?` + (loggingOut ? ' This includes your current session.' : '');
+    if (!await window.__pm_shared.showConfirm(msg, 'Confirm')) return;
+    try {
+        const res = await postSessionRevoke({ keys });
+        window.__pm_shared.showToast(`Revoked ${res.revoked} session${res.revoked !== 1 ? 's' : ''}`, 'success');
+        if (loggingOut) {
+            window.location.href = '/login';
+            return;
+        }
+        loadSessions();
+    } catch (err) {
+        window.__pm_shared.showToast('Failed to revoke sessions: ' + (err.message || err), 'error');
+    }
+}
+
+async function forceLogoutUser(btn) {
+    const userId = Number(btn.dataset.userId);
+    if (!userId) return;
+    const self = userId === currentUser?.id;
+    const msg = `Log ${btn.dataset.username || 'this user'} out of every session?` + (self ? ' You will be logged out.' : '');
+    if (!await window.__pm_shared.showConfirm(msg, 'Confirm')) return;
+    try {
+        const res = await postSessionRevoke({ user_id: userId });
+        window.__pm_shared.showToast(`Ended ${res.revoked} session${res.revoked !== 1 ? 's' : ''}`, 'success');
+        if (self) {
+            window.location.href = '/login';
+            return;
+        }
+        loadSessions();
+    } catch (err) {
+        window.__pm_shared.showToast('Failed to log out user: ' + (err.message || err), 'error');
+    }
 }
 
 async function revokeSession(btn) {
@@ -1619,7 +1732,8 @@ async function revokeSession(btn) {
     if (!hash) return;
     if (!confirm('Revoke this session? The user will be logged out.')) return;
     try {
-        await fetch(`/api/v1/sessions/${encodeURIComponent(hash)}`, { method: 'DELETE' });
+        const r = await fetch(`/api/v1/sessions/${encodeURIComponent(hash)}`, { method: 'DELETE' });
+        if (!r.ok) throw new Error(await r.text());
         window.__pm_shared.showToast('Session revoked', 'success');
         loadSessions();
     } catch (err) {
@@ -1633,8 +1747,205 @@ document.addEventListener('DOMContentLoaded', () => {
     if (refreshBtn) {
         refreshBtn.addEventListener('click', loadSessions);
     }
+    const revokeSelectedBtn = document.getElementById('sessions_revoke_selected_btn');
+    if (revokeSelectedBtn) {
+        revokeSelectedBtn.addEventListener('click', revokeSelectedSessions);
+    }
+});
+
+// Four-eyes approvals for dangerous actions (Access sub-tab)
+async function loadActionApprovals() {
+    const container = document.getElementById('approvals_list');
+    if (!container) return;
+    const status = document.getElementById('approvals_status_filter')?.value || '';
+    try {
+        const approvals = await fetchJSON('/api/v1/action-approvals' + (status ? `?status=${encodeURIComponent(status)}` : ''));
+        renderActionApprovals(approvals || []);
+    } catch (err) {
+        container.innerHTML = `<div style="color:var(--danger);">Failed to load approvals: ${escapeHtml(err.message || String(err))}</div>`;
+    }
+}
+
+function renderActionApprovals(approvals) {
+    const container = document.getElementById('approvals_list');
+    if (!container) return;
+    if (!Array.isArray(approvals) || approvals.length === 0) {
+        container.innerHTML = '<div class="muted-text">No approval requests.</div>';
+        return;
+    }
+    const rows = approvals.map(a => {
+        const own = a.requested_by_id === currentUser?.id;
+        let actions = '—';
+        if (a.status === 'pending') {
+            actions = own
+                ? `<button class="ghost-btn" data-approval-id="${a.id}" data-verb="cancel" onclick="decideActionApproval(this)">Cancel</button>`
+                : `<button class="ghost-btn" data-approval-id="${a.id}" data-verb="approve" onclick="decideActionApproval(this)">Approve</button>
+                   <button class="ghost-btn danger-btn" data-approval-id="${a.id}" data-verb="reject" onclick="decideActionApproval(this)">Reject</button>`;
+        }
+        const outcome = a.result ? ` <span class="muted-text" title="${escapeHtml(a.result)}">(${escapeHtml(a.result.slice(0, 60))})</span>` : '';
+        return `<tr>
+            <td>#${a.id}</td>
+            <td>${escapeHtml(a.summary || a.action)}${a.reason ? `<div class="muted-text">${escapeHtml(a.reason)}</div>` : ''}</td>
+            <td>${escapeHtml(a.requested_by || '')}</td>
+            <td>${a.created_at ? new Date(a.created_at).toLocaleString() : 'N/A'}</td>
+            <td>${a.expires_at ? new Date(a.expires_at).toLocaleString() : 'N/A'}</td>
+            <td>${escapeHtml(a.status)}${a.decided_by ? ` by ${escapeHtml(a.decided_by)}` : ''}${outcome}</td>
+            <td style="white-space:nowrap;">${actions}</td>
+        </tr>`;
+    }).join('');
+    container.innerHTML = `<table class="data-table">
+        <thead><tr><th>ID</th><th>Action</th><th>Requested By</th><th>Requested</th><th>Expires</th><th>Status</th><th></th></tr></thead>
+        <tbody>${rows}</tbody>
+    </table>`;
+}
+
+async function decideActionApproval(btn) {
+    const id = btn.dataset.approvalId;
+    const verb = btn.dataset.verb;
+    if (!id || !verb) return;
+    const prompts = {
+        approve: ['Approve and run this action now? It cannot be undone.', 'Approve Action', true],
+        reject: ['Reject this request?', 'Reject Request', false],
+        cancel: ['Withdraw your request?', 'Cancel Request', false]
+    };
+    const [msg, title, dangerous] = prompts[verb] || prompts.reject;
+    if (!await window.__pm_shared.showConfirm(msg, title, dangerous)) return;
+    try {
+        const r = await fetch(`/api/v1/action-approvals/${encodeURIComponent(id)}/${verb}`, { method: 'POST' });
+        if (!r.ok) throw new Error(await r.text());
+        const approval = await r.json();
+        const tone = approval.status === 'failed' ? 'error' : 'success';
+        window.__pm_shared.showToast(`Request #${approval.id} ${approval.status}` + (approval.status === 'failed' && approval.result ? `: ${approval.result}` : ''), tone);
+        loadActionApprovals();
+    } catch (err) {
+        window.__pm_shared.showToast('Failed to update request: ' + (err.message || err), 'error');
+    }
+}
+
+document.addEventListener('DOMContentLoaded', () => {
+    document.getElementById('approvals_refresh_btn')?.addEventListener('click', loadActionApprovals);
+    document.getElementById('approvals_status_filter')?.addEventListener('change', loadActionApprovals);
 });
 
+// ============================================
+// Global Search (header)
+// ============================================
+
+let globalSearchResults = [];
+let globalSearchActive = -1;
+let globalSearchSeq = 0;
+
+async function runGlobalSearch(query) {
+    const panel = document.getElementById('global_search_results');
+    if (!panel) return;
+    if (query.length < 2) {
+        panel.style.display = 'none';
+        globalSearchResults = [];
+        return;
+    }
+    const seq = ++globalSearchSeq;
+    try {
+        const r = await fetch('/api/v1/search?q=' + encodeURIComponent(query) + '&limit=20');
+        if (!r.ok) throw new Error(await r.text());
+        const data = await r.json();
+        if (seq !== globalSearchSeq) return; // A newer query is in flight
+        globalSearchResults = data.results || [];
+        globalSearchActive = globalSearchResults.length ? 0 : -1;
+        renderGlobalSearchResults(data.truncated ? data.total : 0);
+    } catch (err) {
+        if (seq !== globalSearchSeq) return;
+        panel.innerHTML = `<div class="global-search-empty">Search failed: ${escapeHtml(err.message || String(err))}</div>`;
+        panel.style.display = '';
+    }
+}
+
+function renderGlobalSearchResults(truncatedTotal) {
+    const panel = document.getElementById('global_search_results');
+    if (!panel) return;
+    if (!globalSearchResults.length) {
+        panel.innerHTML = '<div class="global-search-empty">No matches</div>';
+        panel.style.display = '';
+        return;
+    }
+    const rows = globalSearchResults.map((res, i) => {
+        const where = [res.tenant_name || res.tenant_id, res.type === 'device' ? res.agent_name : ''].filter(Boolean).join(' / ');
+        const matched = `${res.match_field.replace(/_/g, ' ')}: ${res.match_value}`;
+        return `<button type="button" class="global-search-item${i === globalSearchActive ? ' active' : ''}" data-index="${i}">
+            <div><span class="global-search-type">${escapeHtml(res.type)}</span><strong>${escapeHtml(res.title || res.id)}</strong></div>
+            <div class="global-search-meta">${escapeHtml([res.subtitle, where].filter(Boolean).join(' · '))}</div>
+            <div class="global-search-meta">${escapeHtml(matched)}</div>
+        </button>`;
+    });
+    if (truncatedTotal) {
+        rows.push(`<div class="global-search-empty">Showing ${globalSearchResults.length} of ${truncatedTotal} matches; refine your search</div>`);
+    }
+    panel.innerHTML = rows.join('');
+    panel.style.display = '';
+    panel.querySelectorAll('.global-search-item').forEach(btn => {
+        btn.addEventListener('mousedown', (e) => {
+            e.preventDefault(); // Keep focus so blur doesn't hide the panel first
+            openGlobalSearchResult(globalSearchResults[Number(btn.dataset.index)]);
+        });
+    });
+}
+
+function openGlobalSearchResult(res) {
+    if (!res) return;
+    const input = document.getElementById('global_search_input');
+    const panel = document.getElementById('global_search_results');
+    if (panel) panel.style.display = 'none';
+    if (input) input.blur();
+    switch (res.type) {
+        case 'device':
+            switchTab('devices');
+            showPrinterDetails(res.id, 'saved');
+            break;
+        case 'agent':
+            switchTab('agents');
+            viewAgentDetails(res.id);
+            break;
+        case 'tenant': {
+            if (!document.querySelector('[data-tab="admin"]')) {
+                window.__pm_shared.showToast(`Tenant ${res.title} (${res.id})`, 'info');
+                return;
+            }
+            switchTab('admin');
+            switchAdminView('tenants');
+            tenantsVM.filters.query = (res.title || res.id).toLowerCase();
+            const tenantInput = document.getElementById('tenants_search');
+            if (tenantInput) tenantInput.value = res.title || res.id;
+            applyTenantFilters();
+            break;
+        }
+    }
+}
+
+function initGlobalSearch() {
+    const input = document.getElementById('global_search_input');
+    const panel = document.getElementById('global_search_results');
+    if (!input || !panel) return;
+    const search = debounce(() => runGlobalSearch(input.value.trim()), 250);
+    input.addEventListener('input', search);
+    input.addEventListener('focus', () => {
+        if (globalSearchResults.length && input.value.trim().length >= 2) panel.style.display = '';
+    });
+    input.addEventListener('blur', () => { panel.style.display = 'none'; });
+    input.addEventListener('keydown', (e) => {
+        if (e.key === 'Escape') {
+            panel.style.display = 'none';
+            input.blur();
+        } else if ((e.key === 'ArrowDown' || e.key === 'ArrowUp') && globalSearchResults.length) {
+            e.preventDefault();
+            const step = e.key === 'ArrowDown' ? 1 : -1;
+            globalSearchActive = (globalSearchActive + step + globalSearchResults.length) % globalSearchResults.length;
+            panel.querySelectorAll('.global-search-item').forEach((btn, i) => btn.classList.toggle('active', i === globalSearchActive));
+        } else if (e.key === 'Enter' && globalSearchActive >= 0) {
+            e.preventDefault();
+            openGlobalSearchResult(globalSearchResults[globalSearchActive]);
+        }
+    });
+}
+
 // ============================================
 // Alerts Tab Functions (operator-visible)
 // ============================================
@@ -1672,6 +1983,16 @@ function initAlertsSubTabs() {
         }
     });
 
+    // Report builder (operators+)
+    const builderCard = document.getElementById('report_builder_card');
+    if (builderCard) {
+        builderCard.style.display = userCan('reports.build') ? '' : 'none';
+    }
+    const builderBtn = document.getElementById('open_report_builder_btn');
+    if (builderBtn) {
+        builderBtn.addEventListener('click', () => openReportBuilder());
+    }
+
     // Alert history filters - use applyAlertHistoryFilters for in-memory filtering
     const historyTimeFilter = document.getElementById('alerts_history_time_filter');
     const historyStatusFilter = document.getElementById('alerts_history_status_filter');
@@ -1827,7 +2148,7 @@ async function loadRecentAlertsPreview() {
     if (!recentContainer) return;
 
     try {
-        const resp = await fetch('/api/v1/alerts?limit=5');
+        const resp = await fetch('/api/v1/alerts?limit=5&top_level=true');
         if (!resp.ok) throw new Error(`HTTP ${resp.status}`);
         const data = await resp.json();
         const alerts = data.alerts || [];
@@ -1896,6 +2217,7 @@ async function loadActiveAlerts(append = false) {
     try {
         const params = new URLSearchParams({
             status: 'active',
+            top_level: 'true',
             limit: alertsInfiniteScroll.limit.toString(),
             offset: alertsInfiniteScroll.offset.toString()
         });
@@ -2038,6 +2360,7 @@ function renderAlertCard(alert) {
     if (alert.device_serial) details.push(`Device: ${alert.device_serial}`);
     if (alert.agent_id) details.push(`Agent: ${alert.agent_id.substring(0, 8)}...`);
     if (alert.site_id) details.push(`Site: ${alert.site_id}`);
+    if (alert.type === 'incident') details.push(`${alert.child_count || 0} grouped alert${alert.child_count === 1 ? '' : 's'}`);
 
     return `
         <div class="alert-card alert-${severityClass}" data-alert-id="${alert.id}">
@@ -2054,6 +2377,7 @@ function renderAlertCard(alert) {
             </div>
             <div class="alert-card-actions">
                 ${alert.status !== 'acknowledged' ? `<button class="btn btn-sm alert-action-btn" data-action="acknowledge" data-alert-id="${alert.id}">Acknowledge</button>` : ''}
+                <button class="btn btn-sm alert-action-btn" data-action="snooze" data-alert-id="${alert.id}" title="Hide this alert for 4 hours">Snooze 4h</button>
                 <button class="btn btn-sm btn-success alert-action-btn" data-action="resolve" data-alert-id="${alert.id}">Resolve</button>
             </div>
         </div>
@@ -2062,7 +2386,12 @@ function renderAlertCard(alert) {
 
 async function handleAlertAction(action, alertId) {
     try {
-        const resp = await fetch(`/api/v1/alerts/${alertId}/${action}`, { method: 'POST' });
+        const opts = { method: 'POST' };
+        if (action === 'snooze') {
+            opts.headers = { 'Content-Type': 'application/json' };
+            opts.body = JSON.stringify({ minutes: 240 });
+        }
+        const resp = await fetch(`/api/v1/alerts/${alertId}/${action}`, opts);
         if (!resp.ok) throw new Error(`HTTP ${resp.status}`);
         window.__pm_shared.showToast(`Alert ${action}d successfully`, 'success');
         loadActiveAlerts(); // Refresh the list
@@ -2302,6 +2631,13 @@ async function loadRecentReports() {
         'top_printers': 'Top Printers',
         'offline_devices': 'Offline Devices',
         'error_devices': 'Error Devices',
+        'security_posture': 'Security Posture',
+        'capacity_planning': 'Capacity Planning',
+        'toner_yield': 'Toner Yield',
+        'maintenance_log': 'Maintenance Log',
+        'data_quality': 'Data Quality',
+        'sustainability': 'Sustainability',
+        'availability': 'Availability (SLA)',
         'cost_analysis': 'Cost Analysis',
         'custom': 'Custom'
     };
@@ -2334,10 +2670,16 @@ async function loadRecentReports() {
                             <tr>
                                 <td>${escapeHtml(run.report_name || 'Report #' + run.report_id)}</td>
                                 <td><span class="badge">${typeDisplay}</span></td>
-                                <td><span class="badge badge-${run.status === 'completed' ? 'success' : run.status === 'failed' ? 'danger' : 'warning'}">${run.status}</span></td>
+                                <td>
+                                    <span class="badge badge-${run.status === 'completed' ? 'success' : run.status === 'failed' ? 'danger' : 'warning'}">${run.status}</span>
+                                    ${run.delivery_error ? `<span class="badge badge-danger" title="${escapeHtml(run.delivery_error)}">email failed</span>`
+                                        : run.delivered_to?.length ? `<span class="badge" title="${escapeHtml(run.delivered_to.join(', '))}">emailed</span>` : ''}
+                                </td>
                                 <td>${new Date(run.started_at).toLocaleString()}</td>
                                 <td>
-                                    ${run.status === 'completed' ? `
+                                    ${run.status === 'completed' && (run.format === 'xlsx' || run.format === 'pdf') ? `
+                                        <button class="btn btn-sm" onclick="downloadReportRun(${run.id}, '${run.format}')">${run.format.toUpperCase()}</button>
+                                    ` : run.status === 'completed' ? `
                                         <button class="btn btn-sm" onclick="downloadReportRun(${run.id}, 'csv')">CSV</button>
                                         <button class="btn btn-sm" onclick="downloadReportRun(${run.id}, 'json')">JSON</button>
                                     ` : ''}
@@ -2355,6 +2697,11 @@ async function loadRecentReports() {
 }
 
 async function downloadReportRun(runId, format) {
+    if (format === 'xlsx' || format === 'pdf') {
+        // Workbooks and PDFs are binary; let the server serve the decoded file
+        window.location.href = `/api/v1/report-runs/${runId}/download`;
+        return;
+    }
     try {
         const resp = await fetch(`/api/v1/report-runs/${runId}`);
         if (!resp.ok) throw new Error(`HTTP ${resp.status}`);
@@ -2484,15 +2831,19 @@ async function generateReport(type) {
         'fleet': 'device_inventory',
         'usage': 'usage_summary',
         'supply': 'supplies_status',
-        'alert': 'alert_summary'
+        'alert': 'alert_summary',
+        'security': 'security_posture',
+        'capacity': 'capacity_planning',
+        'sustainability': 'sustainability',
+        'availability': 'availability'
     };
     const reportType = typeMap[type] || type;
 
-    // Optional time range selector for usage audit
+    // Optional time range selector for usage, sustainability and availability reports
     let timeRangeType;
     let timeRangeDays;
-    if (type === 'usage') {
-        const rangeEl = document.getElementById('usage_report_range');
+    if (type === 'usage' || type === 'sustainability' || type === 'availability') {
+        const rangeEl = document.getElementById(`${type}_report_range`);
         const selected = rangeEl?.value || 'last_30d';
         if (selected === 'custom_365d') {
             timeRangeType = 'custom';
@@ -2561,6 +2912,17 @@ function showReportDownloadModal(run) {
     // Wire download buttons
     const csvBtn = document.getElementById('report_download_csv');
     const jsonBtn = document.getElementById('report_download_json');
+    const xlsxBtn = document.getElementById('report_download_xlsx');
+    const isXLSX = run.format === 'xlsx';
+    if (csvBtn) csvBtn.style.display = isXLSX ? 'none' : '';
+    if (jsonBtn) jsonBtn.style.display = isXLSX ? 'none' : '';
+    if (xlsxBtn) {
+        xlsxBtn.style.display = isXLSX ? '' : 'none';
+        xlsxBtn.onclick = () => {
+            downloadReportRun(run.id, 'xlsx');
+            modal.style.display = 'none';
+        };
+    }
 
     if (csvBtn) {
         csvBtn.onclick = () => {
@@ -2585,6 +2947,433 @@ function showReportDownloadModal(run) {
     modal.style.display = 'flex';
 }
 
+// ============================================
+// Device asset labels
+// ============================================
+
+let deviceLabelTemplates = null;
+
+async function showDeviceLabelsModal(devices) {
+    const modal = document.getElementById('device_labels_modal');
+    if (!modal) return;
+    const serials = (devices || []).map(d => d.serial).filter(Boolean);
+    if (serials.length === 0) {
+        window.__pm_shared.showToast('No devices to label', 'error');
+        return;
+    }
+
+    const select = document.getElementById('device_labels_template');
+    if (!deviceLabelTemplates) {
+        try {
+            const resp = await fetch('/api/v1/devices/labels/templates');
+            if (!resp.ok) throw new Error(`HTTP ${resp.status}`);
+            deviceLabelTemplates = await resp.json();
+        } catch (e) {
+            window.__pm_shared.showToast('Failed to load label templates: ' + e.message, 'error');
+            return;
+        }
+    }
+    if (select && select.options.length === 0) {
+        (deviceLabelTemplates.templates || []).forEach(t => {
+            const opt = document.createElement('option');
+            opt.value = t.id;
+            opt.textContent = `${t.name} - ${t.paper.toUpperCase()}`;
+            opt.dataset.perSheet = t.per_sheet;
+            select.appendChild(opt);
+        });
+        select.value = deviceLabelTemplates.default || '';
+    }
+
+    const info = document.getElementById('device_labels_info');
+    if (info) {
+        info.textContent = `${serials.length} label${serials.length === 1 ? '' : 's'} for the devices currently shown. ` +
+            'Each QR code opens the device in the tech view.';
+    }
+    const skipInput = document.getElementById('device_labels_skip');
+    const syncSkipMax = () => {
+        const perSheet = Number(select?.selectedOptions[0]?.dataset.perSheet || 1);
+        if (skipInput) skipInput.max = String(perSheet - 1);
+    };
+    if (select) select.onchange = syncSkipMax;
+    syncSkipMax();
+
+    const closeModal = () => modal.style.display = 'none';
+    document.getElementById('device_labels_close_x').onclick = closeModal;
+    document.getElementById('device_labels_cancel').onclick = closeModal;
+    document.getElementById('device_labels_download').onclick = async () => {
+        try {
+            const resp = await fetch('/api/v1/devices/labels', {
+                method: 'POST',
+                headers: { 'Content-Type': 'application/json' },
+                body: JSON.stringify({
+                    serials,
+                    template: select ? select.value : '',
+                    skip: Number(skipInput?.value || 0),
+                    outline: !!document.getElementById('device_labels_outline')?.checked,
+                }),
+            });
+            if (!resp.ok) throw new Error((await resp.text()).trim() || `HTTP ${resp.status}`);
+            const blob = await resp.blob();
+            const url = URL.createObjectURL(blob);
+            const a = document.createElement('a');
+            a.href = url;
+            a.download = `device-labels-${new Date().toISOString().split('T')[0]}.pdf`;
+            document.body.appendChild(a);
+            a.click();
+            a.remove();
+            URL.revokeObjectURL(url);
+            closeModal();
+        } catch (e) {
+            window.__pm_shared.showToast('Failed to create labels: ' + e.message, 'error');
+        }
+    };
+
+    modal.style.display = 'flex';
+}
+
+// ============================================
+// Device comparison
+// ============================================
+
+const DEVICE_COMPARE_MAX = 8;
+
+function showDeviceCompareModal(devices) {
+    const modal = document.getElementById('device_compare_modal');
+    if (!modal) return;
+    const list = (devices || []).filter(d => d && d.serial);
+    if (list.length < 2) {
+        window.__pm_shared.showToast('At least two devices are needed to compare', 'error');
+        return;
+    }
+
+    const picker = document.getElementById('device_compare_picker');
+    const search = document.getElementById('device_compare_search');
+    const result = document.getElementById('device_compare_result');
+    const selected = new Set();
+    if (result) result.innerHTML = '';
+    if (search) search.value = '';
+
+    const renderPicker = () => {
+        if (!picker) return;
+        const term = (search?.value || '').trim().toLowerCase();
+        const shown = list.filter(d => !term ||
+            [d.serial, d.model, d.manufacturer, d.location, d.ip].some(v => (v || '').toLowerCase().includes(term)));
+        picker.innerHTML = shown.slice(0, 200).map(d => `
+            <label class="inline-check">
+                <input type="checkbox" value="${escapeHtml(d.serial)}" ${selected.has(d.serial) ? 'checked' : ''} />
+                <span>${escapeHtml(d.serial)}</span>
+                <span class="muted-text">${escapeHtml([d.manufacturer, d.model].filter(Boolean).join(' '))}${d.location ? ' · ' + escapeHtml(d.location) : ''}</span>
+            </label>`).join('') || '<div class="muted-text">No devices match.</div>';
+    };
+    if (picker) {
+        picker.onchange = (event) => {
+            const box = event.target.closest('input[type="checkbox"]');
+            if (!box) return;
+            if (box.checked && selected.size >= DEVICE_COMPARE_MAX) {
+                box.checked = false;
+                window.__pm_shared.showToast(`Compare at most ${DEVICE_COMPARE_MAX} devices`, 'error');
+                return;
+            }
+            if (box.checked) selected.add(box.value); else selected.delete(box.value);
+        };
+    }
+    if (search) search.oninput = renderPicker;
+    renderPicker();
+
+    const closeModal = () => modal.style.display = 'none';
+    document.getElementById('device_compare_close_x').onclick = closeModal;
+    document.getElementById('device_compare_cancel').onclick = closeModal;
+    document.getElementById('device_compare_run').onclick = async () => {
+        if (selected.size < 2) {
+            window.__pm_shared.showToast('Select at least two devices', 'error');
+            return;
+        }
+        const days = document.getElementById('device_compare_days')?.value || '30';
+        const params = new URLSearchParams({ serials: Array.from(selected).join(','), days });
+        if (result) result.innerHTML = '<div class="muted-text">Comparing…</div>';
+        try {
+            const data = await fetchJSON('/api/v1/devices/compare?' + params.toString());
+            renderDeviceComparison(result, data);
+        } catch (e) {
+            if (result) result.innerHTML = '';
+            window.__pm_shared.showToast('Failed to compare devices: ' + ((e.body || '').trim() || e.message), 'error');
+        }
+    };
+
+    modal.style.display = 'flex';
+}
+
+// showDeviceExportModal downloads the devices (or their metrics readings) as
+// CSV or XLSX. The server applies tenant scoping; the page's tenant, agent,
+// manufacturer and search filters narrow the export.
+function showDeviceExportModal() {
+    const modal = document.getElementById('device_export_modal');
+    if (!modal) return;
+    const dataset = document.getElementById('device_export_dataset');
+    const days = document.getElementById('device_export_days');
+    if (dataset && days) {
+        dataset.onchange = () => { days.disabled = dataset.value !== 'metrics'; };
+        days.disabled = dataset.value !== 'metrics';
+    }
+
+    const closeModal = () => modal.style.display = 'none';
+    document.getElementById('device_export_close_x').onclick = closeModal;
+    document.getElementById('device_export_cancel').onclick = closeModal;
+    document.getElementById('device_export_run').onclick = () => {
+        const filters = devicesVM.filters;
+        const params = new URLSearchParams({ format: document.getElementById('device_export_format')?.value || 'csv' });
+        if (filters.tenantId) params.set('tenant_id', filters.tenantId);
+        if (filters.agentId) params.set('agent_id', filters.agentId);
+        if (filters.manufacturer) params.set('manufacturer', filters.manufacturer);
+        if (filters.query) params.set('q', filters.query);
+        let path = '/api/v1/devices/export';
+        if (dataset?.value === 'metrics') {
+            path = '/api/v1/metrics/export';
+            const since = new Date(Date.now() - Number(days?.value || 30) * 86400000);
+            params.set('since', since.toISOString().replace(/\.\d{3}Z$/, 'Z'));
+        }
+        closeModal();
+        window.location.href = path + '?' + params.toString();
+    };
+
+    modal.style.display = 'flex';
+}
+
+// renderDeviceComparison draws one column per device. For each metric the
+// best value is highlighted; higher is better only for uptime.
+function renderDeviceComparison(container, data) {
+    if (!container) return;
+    const devices = (data && data.devices) || [];
+    const num = (v, digits = 0) => (v === null || v === undefined) ? '—' :
+        Number(v).toLocaleString(undefined, { minimumFractionDigits: digits, maximumFractionDigits: digits });
+    const rows = [
+        { label: 'Pages', value: d => d.pages, fmt: v => num(v) },
+        { label: 'Pages / day', value: d => d.pages_per_day, fmt: v => num(v, 1) },
+        { label: 'Mono pages', value: d => d.mono_pages, fmt: v => num(v) },
+        { label: 'Color pages', value: d => d.color_pages, fmt: v => num(v) },
+        { label: 'Scans', value: d => d.scans, fmt: v => num(v) },
+        { label: 'Uptime', value: d => d.uptime_pct, fmt: v => num(v, 2) + '%', best: 'max' },
+        { label: 'Offline events', value: d => d.offline_events, fmt: v => num(v), best: 'min' },
+        { label: 'Jams', value: d => d.jams, fmt: v => num(v) },
+        { label: 'Jams / 10k pages', value: d => d.jams_per_10k_pages, fmt: v => num(v, 2), best: 'min' },
+        { label: 'Supply cost', value: d => d.supply_cost, fmt: v => num(v, 2) },
+        { label: 'Cost / page', value: d => d.cost_per_page, fmt: v => num(v, 4), best: 'min' },
+    ];
+
+    const head = devices.map(d => `
+        <th>
+            <div>${escapeHtml(d.serial)}</div>
+            <div class="muted-text">${escapeHtml([d.manufacturer, d.model].filter(Boolean).join(' '))}</div>
+            ${d.location ? `<div class="muted-text">${escapeHtml(d.location)}</div>` : ''}
+        </th>`).join('');
+    const body = rows.map(row => {
+        const values = devices.map(row.value);
+        const present = values.filter(v => v !== null && v !== undefined);
+        let best = null;
+        if (row.best && present.length > 1) {
+            best = row.best === 'max' ? Math.max(...present) : Math.min(...present);
+        }
+        const cells = values.map(v => {
+            const cls = best !== null && v === best ? ' class="device-compare-best"' : '';
+            return `<td${cls}>${escapeHtml(row.fmt(v))}</td>`;
+        }).join('');
+        return `<tr><th scope="row">${escapeHtml(row.label)}</th>${cells}</tr>`;
+    }).join('');
+
+    const notes = [];
+    devices.forEach(d => {
+        if (d.counter_reset) notes.push(`${d.serial}: a page counter went backwards; volumes may be understated.`);
+        if (d.observed_days < data.days - 1) notes.push(`${d.serial}: metrics cover ${num(d.observed_days, 1)} of ${data.days} days.`);
+        if ((d.supplies_unpriced || []).length) notes.push(`${d.serial}: no cartridge price for ${d.supplies_unpriced.join(', ')}.`);
+    });
+
+    container.innerHTML = `
+        <table class="simple-table device-compare-table">
+            <thead><tr><th></th>${head}</tr></thead>
+            <tbody>${body}</tbody>
+        </table>
+        ${notes.map(n => `<div class="device-compare-note">${escapeHtml(n)}</div>`).join('')}`;
+}
+
+// ============================================
+// Report Builder (operators+)
+// ============================================
+
+let reportBuilderFields = null;
+let reportBuilderFilterOps = [];
+
+async function openReportBuilder() {
+    const modal = document.getElementById('report_builder_modal');
+    if (!modal) return;
+
+    if (!reportBuilderFields) {
+        try {
+            const resp = await fetch('/api/v1/reports/builder/fields');
+            if (!resp.ok) throw new Error(`HTTP ${resp.status}`);
+            const data = await resp.json();
+            reportBuilderFields = data.entities || {};
+            reportBuilderFilterOps = data.filter_ops || [];
+        } catch (err) {
+            console.error('Failed to load report builder fields:', err);
+            window.__pm_shared.showToast('Failed to load report builder', 'error');
+            return;
+        }
+    }
+
+    const entitySelect = document.getElementById('report_builder_entity');
+    if (entitySelect && !entitySelect.dataset.wired) {
+        entitySelect.dataset.wired = '1';
+        entitySelect.addEventListener('change', () => renderReportBuilderFields(entitySelect.value));
+        document.getElementById('report_builder_add_filter')?.addEventListener('click', () => addReportBuilderFilter());
+        document.getElementById('report_builder_save')?.addEventListener('click', saveReportBuilder);
+        const closeModal = () => modal.style.display = 'none';
+        document.getElementById('report_builder_close_x')?.addEventListener('click', closeModal);
+        document.getElementById('report_builder_cancel')?.addEventListener('click', closeModal);
+    }
+
+    document.getElementById('report_builder_name').value = '';
+    document.getElementById('report_builder_error').style.display = 'none';
+    renderReportBuilderFields(entitySelect ? entitySelect.value : 'devices');
+    modal.style.display = 'flex';
+}
+
+function renderReportBuilderFields(entity) {
+    const fields = (reportBuilderFields && reportBuilderFields[entity]) || [];
+
+    const columns = document.getElementById('report_builder_columns');
+    if (columns) {
+        columns.innerHTML = fields.map(f => `
+            <label class="inline-check" style="font-size:13px;">
+                <input type="checkbox" value="${escapeHtml(f.name)}" checked />
+                <span>${escapeHtml(f.label)}</span>
+            </label>
+        `).join('');
+    }
+
+    const options = fields.map(f => `<option value="${escapeHtml(f.name)}">${escapeHtml(f.label)}</option>`).join('');
+    const group = document.getElementById('report_builder_group');
+    if (group) group.innerHTML = '<option value="">None</option>' + options;
+    const order = document.getElementById('report_builder_order');
+    if (order) order.innerHTML = '<option value="">Default</option><option value="count">Count (grouped)</option>' + options;
+
+    const filters = document.getElementById('report_builder_filters');
+    if (filters) filters.innerHTML = '';
+
+    const range = document.getElementById('report_builder_range');
+    if (range) range.disabled = entity !== 'metrics';
+}
+
+function addReportBuilderFilter() {
+    const container = document.getElementById('report_builder_filters');
+    const entity = document.getElementById('report_builder_entity')?.value || 'devices';
+    const fields = (reportBuilderFields && reportBuilderFields[entity]) || [];
+    if (!container || fields.length === 0) return;
+
+    const row = document.createElement('div');
+    row.className = 'report-builder-filter';
+    row.style.cssText = 'display:flex;gap:6px;margin-bottom:6px;';
+    row.innerHTML = `
+        <select class="rb-field" style="flex:1;">
+            ${fields.map(f => `<option value="${escapeHtml(f.name)}">${escapeHtml(f.label)}</option>`).join('')}
+        </select>
+        <select class="rb-op" style="width:110px;">
+            ${reportBuilderFilterOps.map(op => `<option value="${escapeHtml(op)}">${escapeHtml(op.replace('_', ' '))}</option>`).join('')}
+        </select>
+        <input class="rb-value" type="text" style="flex:1;" placeholder="Value" />
+        <button class="btn-outline btn-sm rb-remove" type="button" title="Remove">&times;</button>
+    `;
+    row.querySelector('.rb-remove').addEventListener('click', () => row.remove());
+    container.appendChild(row);
+}
+
+async function saveReportBuilder() {
+    const modal = document.getElementById('report_builder_modal');
+    const errorEl = document.getElementById('report_builder_error');
+    const showError = (msg) => {
+        if (errorEl) {
+            errorEl.textContent = msg;
+            errorEl.style.display = 'block';
+        }
+    };
+
+    const name = (document.getElementById('report_builder_name')?.value || '').trim();
+    if (!name) {
+        showError('Report name is required.');
+        return;
+    }
+
+    const entity = document.getElementById('report_builder_entity')?.value || 'devices';
+    const columns = Array.from(document.querySelectorAll('#report_builder_columns input:checked')).map(el => el.value);
+    if (columns.length === 0) {
+        showError('Select at least one column.');
+        return;
+    }
+    const filters = Array.from(document.querySelectorAll('#report_builder_filters .report-builder-filter')).map(row => ({
+        field: row.querySelector('.rb-field').value,
+        op: row.querySelector('.rb-op').value,
+        value: row.querySelector('.rb-value').value
+    }));
+    const groupBy = document.getElementById('report_builder_group')?.value || '';
+    const orderField = document.getElementById('report_builder_order')?.value || '';
+    const desc = document.getElementById('report_builder_desc')?.checked;
+    const limit = parseInt(document.getElementById('report_builder_limit')?.value || '0', 10) || 0;
+
+    const report = {
+        name,
+        type: 'custom',
+        format: document.getElementById('report_builder_format')?.value || 'csv',
+        columns,
+        options_json: JSON.stringify({ entity, filters }),
+        ...(groupBy ? { group_by: [groupBy] } : {}),
+        ...(orderField ? { order_by: desc ? `${orderField} desc` : orderField } : {}),
+        ...(limit > 0 ? { limit } : {}),
+        ...(entity === 'metrics' ? { time_range_type: document.getElementById('report_builder_range')?.value || 'last_30d' } : {})
+    };
+
+    try {
+        const createResp = await fetch('/api/v1/reports', {
+            method: 'POST',
+            headers: { 'Content-Type': 'application/json' },
+            body: JSON.stringify(report)
+        });
+        if (!createResp.ok) {
+            showError((await createResp.text()) || `HTTP ${createResp.status}`);
+            return;
+        }
+        const created = await createResp.json();
+
+        const frequency = document.getElementById('report_builder_frequency')?.value || '';
+        if (frequency) {
+            const scheduleResp = await fetch(`/api/v1/reports/${created.id}/schedules`, {
+                method: 'POST',
+                headers: { 'Content-Type': 'application/json' },
+                body: JSON.stringify({
+                    name: `${name} (${frequency})`,
+                    enabled: true,
+                    frequency,
+                    day_of_week: 1,
+                    day_of_month: 1,
+                    time_of_day: document.getElementById('report_builder_time')?.value || '08:00',
+                    timezone: Intl.DateTimeFormat().resolvedOptions().timeZone || 'UTC'
+                })
+            });
+            if (!scheduleResp.ok) throw new Error(`Failed to schedule report: HTTP ${scheduleResp.status}`);
+        }
+
+        const runResp = await fetch(`/api/v1/reports/${created.id}/run`, { method: 'POST' });
+        if (!runResp.ok) throw new Error(`Failed to run report: HTTP ${runResp.status}`);
+        const run = await runResp.json();
+
+        if (modal) modal.style.display = 'none';
+        window.__pm_shared.showToast('Custom report saved', 'success');
+        showReportDownloadModal(run);
+        loadRecentReports();
+    } catch (err) {
+        console.error('Failed to save custom report:', err);
+        showError(err.message);
+    }
+}
+
 // ============================================
 // Alert Rules Config Functions (admin-only)
 // ============================================
@@ -2663,6 +3452,11 @@ function initAlertRulesUI() {
         newChannelBtn.addEventListener('click', () => showNotificationChannelModal());
     }
 
+    const newTemplateBtn = document.getElementById('new_notification_template_btn');
+    if (newTemplateBtn) {
+        newTemplateBtn.addEventListener('click', () => showNotificationTemplateModal());
+    }
+
     const newScheduleBtn = document.getElementById('new_scheduled_report_btn');
     if (newScheduleBtn) {
         newScheduleBtn.addEventListener('click', () => showScheduledReportModal());
@@ -2690,7 +3484,7 @@ function initAlertRulesUI() {
     }
 
     // Report generation buttons
-    ['fleet', 'usage', 'supply', 'alert'].forEach(type => {
+    ['fleet', 'usage', 'supply', 'alert', 'security', 'capacity', 'sustainability', 'availability'].forEach(type => {
         const btn = document.getElementById(`generate_${type}_report_btn`);
         if (btn) {
             btn.addEventListener('click', () => generateReport(type));
@@ -2702,6 +3496,7 @@ function initAlertRulesUI() {
     initNotificationChannelModal();
     initEscalationPolicyModal();
     initMaintenanceWindowModal();
+    initNotificationTemplateModal();
     initScheduledReportModal();
 }
 
@@ -2941,7 +3736,8 @@ async function loadAlertRules() {
                     </div>`;
             } else {
                 schedulesContainer.innerHTML = schedules.map(s => {
-                    const nextRun = s.next_run ? new Date(s.next_run).toLocaleString() : 'Not scheduled';
+                    const nextRun = s.enabled && s.next_run_at ? new Date(s.next_run_at).toLocaleString() : 'Not scheduled';
+                    const recipients = (s.email_recipients || []).join(', ');
                     return `
                         <div class="config-item" data-schedule-id="${s.id}">
                             <div class="config-item-header">
@@ -2952,13 +3748,16 @@ async function loadAlertRules() {
                                     <div class="config-item-name">
                                         ${escapeHtml(s.name)}
                                         <span class="badge">${s.frequency}</span>
-                                        <span class="badge">${s.report_type}</span>
+                                        <span class="badge">${escapeHtml(s.report_type || '')}</span>
                                         <span class="config-item-status ${s.enabled ? 'enabled' : 'disabled'}">${s.enabled ? 'Enabled' : 'Disabled'}</span>
                                     </div>
                                     <div class="config-item-details">
                                         <span>Next run: ${nextRun}</span>
                                         <span class="config-item-details-divider">•</span>
-                                        <span>Format: ${s.output_format || 'csv'}</span>
+                                        <span>Format: ${escapeHtml(s.output_format || 'csv')}</span>
+                                        <span class="config-item-details-divider">•</span>
+                                        <span>${recipients ? `Email: ${escapeHtml(recipients)}` : 'Not emailed'}</span>
+                                        ${s.failure_count ? `<span class="config-item-details-divider">•</span><span class="error-text">${s.failure_count} failed run(s)</span>` : ''}
                                     </div>
                                 </div>
                             </div>
@@ -2978,6 +3777,8 @@ async function loadAlertRules() {
 
     // Update quick stats after all data loaded
     updateAlertsQuickStats(stats);
+
+    loadNotificationTemplates();
 }
 
 /**
@@ -3189,8 +3990,14 @@ async function runScheduleNow(scheduleId) {
     try {
         const resp = await fetch(`/api/v1/report-schedules/${scheduleId}/run`, { method: 'POST' });
         if (!resp.ok) throw new Error(`HTTP ${resp.status}`);
-        window.__pm_shared.showToast('Report generation started', 'success');
+        const run = await resp.json();
+        if (run.delivery_error) {
+            window.__pm_shared.showToast(`Report generated, but email failed: ${run.delivery_error}`, 'warning');
+        } else {
+            window.__pm_shared.showToast(run.delivered_to?.length ? 'Report generated and emailed' : 'Report generated', 'success');
+        }
         loadRecentReports();
+        loadAlertRules();
     } catch (err) {
         console.error('Failed to run scheduled report:', err);
         window.__pm_shared.showToast('Failed to run scheduled report', 'error');
@@ -4350,6 +5157,265 @@ async function saveMaintenanceWindow() {
     }
 }
 
+// ============================================
+// Notification Templates
+// ============================================
+
+let cachedNotificationTemplates = [];
+let notificationTemplateDefaults = {};
+let notificationTemplateVariables = [];
+let notificationTemplateModalInitialized = false;
+let notificationTemplatePreviewTimer = null;
+let notificationTemplateLastField = null;
+
+async function loadNotificationTemplates() {
+    const container = document.getElementById('notification_templates_list');
+    if (!container) return;
+    try {
+        const resp = await fetch('/api/v1/notification-templates');
+        if (!resp.ok) throw new Error(`HTTP ${resp.status}`);
+        const data = await resp.json();
+        cachedNotificationTemplates = data.templates || [];
+        notificationTemplateDefaults = data.defaults || {};
+        notificationTemplateVariables = data.variables || [];
+    } catch (err) {
+        console.error('Failed to load notification templates:', err);
+        container.innerHTML = '<div class="error-text">Failed to load notification templates.</div>';
+        return;
+    }
+
+    const badge = document.getElementById('templates_badge');
+    if (badge) badge.textContent = cachedNotificationTemplates.length;
+
+    if (cachedNotificationTemplates.length === 0) {
+        container.innerHTML = `
+            <div class="config-empty-state">
+                <div class="config-empty-state-title">Using built-in templates</div>
+                <div class="config-empty-state-text">Customize the email or webhook template for all tenants or for a single tenant.</div>
+            </div>`;
+        return;
+    }
+
+    await ensureTenantDirectory();
+    container.innerHTML = cachedNotificationTemplates.map(t => {
+        const tenant = t.tenant_id ? (getTenantInfo(t.tenant_id)?.name || t.tenant_id) : 'All tenants (global)';
+        return `
+            <div class="config-item" data-template-id="${t.id}">
+                <div class="config-item-header">
+                    <div class="config-item-info">
+                        <div class="config-item-name">
+                            ${escapeHtml(tenant)}
+                            <span class="badge">${escapeHtml(t.channel_type)}</span>
+                            ${t.html_body ? '<span class="badge">HTML</span>' : ''}
+                        </div>
+                        <div class="config-item-details">
+                            ${t.subject ? `<span>${escapeHtml(t.subject)}</span><span class="config-item-details-divider">•</span>` : ''}
+                            <span>Updated ${escapeHtml(formatRelativeTime(t.updated_at))}${t.updated_by ? ' by ' + escapeHtml(t.updated_by) : ''}</span>
+                        </div>
+                    </div>
+                </div>
+                <div class="config-item-actions">
+                    <button class="btn btn-sm" onclick="editNotificationTemplate(${t.id})">Edit</button>
+                </div>
+            </div>`;
+    }).join('');
+}
+
+function editNotificationTemplate(id) {
+    const tmpl = cachedNotificationTemplates.find(t => t.id === id);
+    if (tmpl) showNotificationTemplateModal(tmpl);
+}
+
+function initNotificationTemplateModal() {
+    const modal = document.getElementById('notification_template_modal');
+    if (!modal || notificationTemplateModalInitialized) return;
+    notificationTemplateModalInitialized = true;
+
+    const close = () => { modal.style.display = 'none'; };
+    document.getElementById('notification_template_modal_close_x')?.addEventListener('click', close);
+    document.getElementById('notification_template_cancel')?.addEventListener('click', close);
+    document.getElementById('notification_template_save')?.addEventListener('click', saveNotificationTemplate);
+    document.getElementById('notification_template_delete')?.addEventListener('click', deleteNotificationTemplate);
+    document.getElementById('template_preview_btn')?.addEventListener('click', previewNotificationTemplate);
+    document.getElementById('template_test_btn')?.addEventListener('click', sendNotificationTemplateTest);
+    document.getElementById('template_preview_alert_id')?.addEventListener('change', previewNotificationTemplate);
+    modal.addEventListener('click', (e) => {
+        if (e.target === modal) close();
+    });
+
+    // Switching tenant or channel type loads the template that applies there
+    ['template_tenant', 'template_channel_type'].forEach(id => {
+        document.getElementById(id)?.addEventListener('change', () => {
+            const tenantId = document.getElementById('template_tenant').value;
+            const channelType = document.getElementById('template_channel_type').value;
+            const existing = cachedNotificationTemplates.find(t => (t.tenant_id || '') === tenantId && t.channel_type === channelType);
+            fillNotificationTemplateForm(existing || null, tenantId, channelType);
+        });
+    });
+
+    ['template_subject', 'template_text_body', 'template_html_body'].forEach(id => {
+        const el = document.getElementById(id);
+        if (!el) return;
+        el.addEventListener('focus', () => { notificationTemplateLastField = el; });
+        el.addEventListener('input', () => {
+            clearTimeout(notificationTemplatePreviewTimer);
+            notificationTemplatePreviewTimer = setTimeout(previewNotificationTemplate, 400);
+        });
+    });
+}
+
+async function showNotificationTemplateModal(existing = null) {
+    const modal = document.getElementById('notification_template_modal');
+    if (!modal) return;
+    initNotificationTemplateModal();
+
+    const tenants = await ensureTenantDirectory();
+    const tenantSelect = document.getElementById('template_tenant');
+    tenantSelect.innerHTML = '<option value="">All tenants (global)</option>' + tenants.map(t => {
+        const id = normalizeTenantId(t);
+        return `<option value="${escapeHtml(id)}">${escapeHtml(t.name || id)}</option>`;
+    }).join('');
+
+    const variables = document.getElementById('template_variables');
+    variables.innerHTML = notificationTemplateVariables.map(v =>
+        `<button type="button" class="notification-template-variable" title="${escapeHtml(v.description)}" data-insert="${escapeHtml(v.name)}">${escapeHtml(v.name)}</button>`
+    ).join('');
+    variables.querySelectorAll('[data-insert]').forEach(btn => {
+        btn.addEventListener('click', () => insertNotificationTemplateVariable(btn.dataset.insert));
+    });
+
+    fillNotificationTemplateForm(existing, existing?.tenant_id || '', existing?.channel_type || 'email');
+    modal.style.display = 'flex';
+}
+
+function fillNotificationTemplateForm(tmpl, tenantId, channelType) {
+    const modal = document.getElementById('notification_template_modal');
+    const source = tmpl || notificationTemplateDefaults[channelType] || {};
+    document.getElementById('template_tenant').value = tenantId;
+    document.getElementById('template_channel_type').value = channelType;
+    document.getElementById('template_subject').value = source.subject || '';
+    document.getElementById('template_text_body').value = source.text_body || '';
+    document.getElementById('template_html_body').value = source.html_body || '';
+
+    const isEmail = channelType === 'email';
+    document.getElementById('template_subject_field').style.display = isEmail ? '' : 'none';
+    document.getElementById('template_html_field').style.display = isEmail ? '' : 'none';
+    document.getElementById('template_text_label').innerHTML = isEmail
+        ? 'Plain text body <span class="required">*</span>'
+        : 'JSON body <span class="required">*</span> <small class="muted-text">(quote values with the json function)</small>';
+    document.getElementById('notification_template_delete').style.display = tmpl ? '' : 'none';
+    modal.dataset.editId = tmpl ? tmpl.id : '';
+
+    const channelSelect = document.getElementById('template_test_channel');
+    const channels = (cachedNotificationChannels || []).filter(ch => ch.type === channelType);
+    channelSelect.innerHTML = channels.length
+        ? channels.map(ch => `<option value="${ch.id}">${escapeHtml(ch.name)}</option>`).join('')
+        : `<option value="">No ${escapeHtml(channelType)} channels</option>`;
+    document.getElementById('template_test_btn').disabled = channels.length === 0;
+
+    previewNotificationTemplate();
+}
+
+function insertNotificationTemplateVariable(text) {
+    const field = notificationTemplateLastField && notificationTemplateLastField.offsetParent !== null
+        ? notificationTemplateLastField
+        : document.getElementById('template_text_body');
+    const start = field.selectionStart ?? field.value.length;
+    const end = field.selectionEnd ?? field.value.length;
+    field.value = field.value.slice(0, start) + text + field.value.slice(end);
+    field.focus();
+    field.selectionStart = field.selectionEnd = start + text.length;
+    previewNotificationTemplate();
+}
+
+function collectNotificationTemplate() {
+    const channelType = document.getElementById('template_channel_type').value;
+    const tmpl = {
+        tenant_id: document.getElementById('template_tenant').value,
+        channel_type: channelType,
+        text_body: document.getElementById('template_text_body').value
+    };
+    if (channelType === 'email') {
+        tmpl.subject = document.getElementById('template_subject').value;
+        tmpl.html_body = document.getElementById('template_html_body').value;
+    }
+    return tmpl;
+}
+
+async function postNotificationTemplateAction(path, body) {
+    const resp = await fetch(path, {
+        method: 'POST',
+        headers: { 'Content-Type': 'application/json' },
+        body: JSON.stringify(body)
+    });
+    const data = await resp.json().catch(() => ({}));
+    if (!resp.ok) throw new Error(data.error || `HTTP ${resp.status}`);
+    return data;
+}
+
+async function previewNotificationTemplate() {
+    const errorBox = document.getElementById('template_preview_error');
+    const subject = document.getElementById('template_preview_subject');
+    const text = document.getElementById('template_preview_text');
+    const html = document.getElementById('template_preview_html');
+    const alertId = parseInt(document.getElementById('template_preview_alert_id').value, 10);
+    const body = { template: collectNotificationTemplate() };
+    if (alertId > 0) body.alert_id = alertId;
+    try {
+        const rendered = await postNotificationTemplateAction('/api/v1/notification-templates/preview', body);
+        errorBox.style.display = 'none';
+        subject.textContent = rendered.subject ? 'Subject: ' + rendered.subject : '';
+        text.textContent = rendered.text || '';
+        html.style.display = rendered.html ? '' : 'none';
+        html.srcdoc = rendered.html || '';
+    } catch (err) {
+        errorBox.textContent = err.message;
+        errorBox.style.display = 'block';
+    }
+}
+
+async function sendNotificationTemplateTest() {
+    const channelId = parseInt(document.getElementById('template_test_channel').value, 10);
+    if (!channelId) return;
+    const body = { template: collectNotificationTemplate(), channel_id: channelId };
+    const alertId = parseInt(document.getElementById('template_preview_alert_id').value, 10);
+    if (alertId > 0) body.alert_id = alertId;
+    try {
+        await postNotificationTemplateAction('/api/v1/notification-templates/test', body);
+        window.__pm_shared.showToast('Test notification sent', 'success');
+    } catch (err) {
+        window.__pm_shared.showToast('Test failed: ' + err.message, 'error');
+    }
+}
+
+async function saveNotificationTemplate() {
+    const modal = document.getElementById('notification_template_modal');
+    try {
+        await postNotificationTemplateAction('/api/v1/notification-templates', collectNotificationTemplate());
+        window.__pm_shared.showToast('Template saved', 'success');
+        modal.style.display = 'none';
+        loadNotificationTemplates();
+    } catch (err) {
+        window.__pm_shared.showToast('Failed to save template: ' + err.message, 'error');
+    }
+}
+
+async function deleteNotificationTemplate() {
+    const modal = document.getElementById('notification_template_modal');
+    const id = modal.dataset.editId;
+    if (!id) return;
+    if (!confirm('Delete this template? Notifications will use the global or built-in template.')) return;
+    try {
+        const resp = await fetch(`/api/v1/notification-templates/${id}`, { method: 'DELETE' });
+        if (!resp.ok) throw new Error(`HTTP ${resp.status}`);
+        window.__pm_shared.showToast('Template deleted', 'success');
+        modal.style.display = 'none';
+        loadNotificationTemplates();
+    } catch (err) {
+        window.__pm_shared.showToast('Failed to delete template', 'error');
+    }
+}
+
 function showScheduledReportModal(existingSchedule = null) {
     const modal = document.getElementById('scheduled_report_modal');
     if (!modal) return;
@@ -4359,17 +5425,37 @@ function showScheduledReportModal(existingSchedule = null) {
 
     const nameInput = form.querySelector('#schedule_name');
     const typeSelect = form.querySelector('#schedule_report_type');
+    const rangeSelect = form.querySelector('#schedule_time_range');
     const formatSelect = form.querySelector('#schedule_format');
     const frequencySelect = form.querySelector('#schedule_frequency');
     const emailInput = form.querySelector('#schedule_email');
     const enabledCheck = form.querySelector('#schedule_enabled');
+    const errorEl = form.querySelector('#schedule_error');
 
     if (nameInput) nameInput.value = existingSchedule?.name || '';
     if (typeSelect) typeSelect.value = existingSchedule?.report_type || 'device_inventory';
+    if (rangeSelect) rangeSelect.value = existingSchedule?.time_range || 'last_30d';
     if (formatSelect) formatSelect.value = existingSchedule?.output_format || 'csv';
-    if (frequencySelect) frequencySelect.value = existingSchedule?.frequency || 'weekly';
-    if (emailInput) emailInput.value = existingSchedule?.delivery_email || '';
+    if (frequencySelect) {
+        frequencySelect.value = existingSchedule?.frequency || 'weekly';
+        frequencySelect.dispatchEvent(new Event('change'));
+    }
+    if (emailInput) emailInput.value = (existingSchedule?.email_recipients || []).join(', ');
     if (enabledCheck) enabledCheck.checked = existingSchedule?.enabled !== false;
+    if (errorEl) errorEl.style.display = 'none';
+
+    // Reports can be scheduled for a single tenant when more than one is visible
+    const tenantField = form.querySelector('#schedule_tenant_field');
+    const tenantSelect = form.querySelector('#schedule_tenant');
+    if (tenantField && tenantSelect) {
+        ensureTenantDirectory().then(tenants => {
+            tenantSelect.innerHTML = '<option value="">All tenants</option>' + tenants
+                .map(t => `<option value="${escapeHtml(t.id)}">${escapeHtml(t.name || t.id)}</option>`)
+                .join('');
+            tenantSelect.value = existingSchedule?.tenant_ids?.[0] || '';
+            tenantField.style.display = tenants.length > 1 ? '' : 'none';
+        });
+    }
 
     modal.dataset.editId = isEdit ? existingSchedule.id : '';
 
@@ -4385,13 +5471,23 @@ async function saveScheduledReport() {
 
     const form = modal.querySelector('form') || modal;
     const editId = modal.dataset.editId;
+    const errorEl = form.querySelector('#schedule_error');
 
+    const tenantId = form.querySelector('#schedule_tenant')?.value || '';
+    const frequency = form.querySelector('#schedule_frequency')?.value || 'weekly';
     const payload = {
-        name: form.querySelector('#schedule_name')?.value || '',
+        name: form.querySelector('#schedule_name')?.value.trim() || '',
         report_type: form.querySelector('#schedule_report_type')?.value || 'device_inventory',
+        time_range: form.querySelector('#schedule_time_range')?.value || 'last_30d',
         output_format: form.querySelector('#schedule_format')?.value || 'csv',
-        frequency: form.querySelector('#schedule_frequency')?.value || 'weekly',
-        delivery_email: form.querySelector('#schedule_email')?.value || '',
+        frequency,
+        day_of_week: frequency === 'weekly' ? parseInt(form.querySelector('#schedule_day')?.value || '1', 10) : 0,
+        day_of_month: frequency === 'monthly' ? parseInt(form.querySelector('#schedule_day_of_month')?.value || '1', 10) : 0,
+        time_of_day: form.querySelector('#schedule_time')?.value || '08:00',
+        timezone: Intl.DateTimeFormat().resolvedOptions().timeZone || 'UTC',
+        email_recipients: (form.querySelector('#schedule_email')?.value || '')
+            .split(',').map(s => s.trim()).filter(Boolean),
+        tenant_ids: tenantId ? [tenantId] : [],
         enabled: form.querySelector('#schedule_enabled')?.checked !== false
     };
 
@@ -4408,13 +5504,17 @@ async function saveScheduledReport() {
             headers: { 'Content-Type': 'application/json' },
             body: JSON.stringify(payload)
         });
-        if (!resp.ok) throw new Error(`HTTP ${resp.status}`);
+        if (!resp.ok) throw new Error((await resp.text()).trim() || `HTTP ${resp.status}`);
 
         window.__pm_shared.showToast(editId ? 'Schedule updated' : 'Schedule created', 'success');
         modal.style.display = 'none';
         loadAlertRules();
     } catch (err) {
         console.error('Failed to save scheduled report:', err);
+        if (errorEl) {
+            errorEl.textContent = err.message;
+            errorEl.style.display = 'block';
+        }
         window.__pm_shared.showToast('Failed to save scheduled report', 'error');
     }
 }
@@ -4474,12 +5574,14 @@ function ensureSettingsViewReady(view) {
     if (view === 'updates') {
         loadSelfUpdateRuns();
         loadAgentUpdatePolicyForUpdatesTab();
+        loadAgentUpdateFailures();
         loadReleaseArtifacts();
         return;
     }
     // fleet
     initSettingsUI();
     loadAgentUpdatePolicyForUpdatesTab();
+    loadAgentUpdateFailures();
     loadReleaseArtifacts();
 }
 
@@ -4554,6 +5656,11 @@ function normalizeServerSettings(raw) {
             rate_limit_max_attempts: safeStr(securitySection.rate_limit_max_attempts),
             rate_limit_block_minutes: safeStr(securitySection.rate_limit_block_minutes),
             rate_limit_window_minutes: safeStr(securitySection.rate_limit_window_minutes),
+            session_lifetime_hours: safeStr(securitySection.session_lifetime_hours),
+            session_idle_minutes: safeStr(securitySection.session_idle_minutes),
+            agent_token_lifetime_days: safeStr(securitySection.agent_token_lifetime_days),
+            require_approvals: safeBool(securitySection.require_approvals),
+            approval_expiry_hours: safeStr(securitySection.approval_expiry_hours),
         },
         tls: {
             mode: safeStr(tlsSection.mode || 'self-signed') || 'self-signed',
@@ -4892,6 +5999,11 @@ function buildServerSettingsPayload() {
             rate_limit_max_attempts: isLocked('security.rate_limit_max_attempts') ? undefined : parseNumber(data.security.rate_limit_max_attempts),
             rate_limit_block_minutes: isLocked('security.rate_limit_block_minutes') ? undefined : parseNumber(data.security.rate_limit_block_minutes),
             rate_limit_window_minutes: isLocked('security.rate_limit_window_minutes') ? undefined : parseNumber(data.security.rate_limit_window_minutes),
+            session_lifetime_hours: isLocked('security.session_lifetime_hours') ? undefined : parseNumber(data.security.session_lifetime_hours),
+            session_idle_minutes: isLocked('security.session_idle_minutes') ? undefined : parseNumber(data.security.session_idle_minutes),
+            agent_token_lifetime_days: isLocked('security.agent_token_lifetime_days') ? undefined : parseNumber(data.security.agent_token_lifetime_days),
+            require_approvals: isLocked('security.require_approvals') ? undefined : Boolean(data.security.require_approvals),
+            approval_expiry_hours: isLocked('security.approval_expiry_hours') ? undefined : parseNumber(data.security.approval_expiry_hours),
         },
         tls: {
             mode: isLocked('tls.mode') ? undefined : (pickString(data.tls.mode) || 'self-signed'),
@@ -5440,6 +6552,7 @@ async function loadDashboard() {
         renderDashboardSummary();
         renderDashboardTree();
         updateDashboardSearchResults();
+        loadTenantDashboardLayout();
     } catch (err) {
         console.error('Failed to load dashboard:', err);
         if (container) {
@@ -5482,6 +6595,88 @@ function renderDashboardSummary() {
     }
 }
 
+// Tenant dashboards: users scoped to a single tenant get that tenant's layout
+// (widget order and visibility) and its onboarding checklist until finished.
+async function loadTenantDashboardLayout() {
+    const tenantIds = getUserTenantIds();
+    if (!isTenantScopedUser() || tenantIds.length !== 1) {
+        applyDashboardLayout(null);
+        renderDashboardOnboarding(null);
+        return;
+    }
+    const base = `/api/v1/tenants/${encodeURIComponent(tenantIds[0])}`;
+    try {
+        const dashboard = await fetchJSON(`${base}/dashboard`);
+        const widgets = (dashboard && dashboard.layout && dashboard.layout.widgets) || [];
+        applyDashboardLayout(widgets);
+        renderDashboardOnboarding(widgets.includes('onboarding') ? await fetchJSON(`${base}/onboarding`) : null);
+    } catch (err) {
+        // Tenancy disabled or no access: keep the full fleet dashboard
+        applyDashboardLayout(null);
+        renderDashboardOnboarding(null);
+    }
+}
+
+function applyDashboardLayout(widgets) {
+    document.querySelectorAll('[data-tab="dashboard"] [data-widget]').forEach(el => {
+        const idx = Array.isArray(widgets) ? widgets.indexOf(el.dataset.widget) : 0;
+        el.classList.toggle('widget-hidden', idx < 0);
+        el.style.order = idx > 0 ? String(idx) : '';
+    });
+}
+
+function renderDashboardOnboarding(checklist) {
+    const panel = document.getElementById('dashboard_onboarding');
+    if (!panel) return;
+    if (!checklist || checklist.finished || !Array.isArray(checklist.steps)) {
+        panel.classList.add('hidden');
+        panel.innerHTML = '';
+        return;
+    }
+    const canEdit = userCan('settings.fleet.write');
+    const rows = checklist.steps.map(step => {
+        let actions = '';
+        if (canEdit && step.status === 'pending') {
+            actions = `<button class="ghost-btn" data-onboarding-step="${escapeHtml(step.id)}" data-onboarding-status="done">Mark done</button>
+                <button class="ghost-btn" data-onboarding-step="${escapeHtml(step.id)}" data-onboarding-status="skipped">Skip</button>`;
+        } else if (canEdit && step.manual) {
+            actions = `<button class="ghost-btn" data-onboarding-step="${escapeHtml(step.id)}" data-onboarding-status="">Undo</button>`;
+        }
+        return `
+            <li class="dashboard-onboarding-step ${escapeHtml(step.status)}">
+                <span class="dashboard-onboarding-check">${step.status === 'done' ? '✓' : step.status === 'skipped' ? '–' : ''}</span>
+                <div class="dashboard-onboarding-text">
+                    <div class="dashboard-onboarding-step-title">${escapeHtml(step.title)}</div>
+                    <div class="muted-text">${escapeHtml(step.status === 'pending' ? step.description : (step.detail || ''))}</div>
+                </div>
+                <div class="dashboard-onboarding-actions">${actions}</div>
+            </li>`;
+    }).join('');
+    panel.innerHTML = `
+        <div class="dashboard-onboarding-header">
+            <span class="dashboard-onboarding-title">Getting started</span>
+            <span class="muted-text">${checklist.completed} of ${checklist.total} steps complete</span>
+        </div>
+        <div class="dashboard-onboarding-progress"><div style="width:${Number(checklist.percent) || 0}%"></div></div>
+        <ul class="dashboard-onboarding-steps">${rows}</ul>`;
+    panel.classList.remove('hidden');
+
+    panel.querySelectorAll('[data-onboarding-step]').forEach(btn => {
+        btn.addEventListener('click', async () => {
+            const status = btn.dataset.onboardingStatus;
+            const url = `/api/v1/tenants/${encodeURIComponent(checklist.tenant_id)}/onboarding/${encodeURIComponent(btn.dataset.onboardingStep)}`;
+            try {
+                const updated = await fetchJSON(url, status
+                    ? { method: 'PUT', headers: { 'Content-Type': 'application/json' }, body: JSON.stringify({ status }) }
+                    : { method: 'DELETE' });
+                renderDashboardOnboarding(updated);
+            } catch (err) {
+                window.__pm_shared.showToast('Failed to update onboarding step: ' + (err.message || err), 'error');
+            }
+        });
+    });
+}
+
 function renderDashboardTree() {
     const container = document.getElementById('dashboard_tree');
     if (!container || !dashboardData) return;
@@ -6103,7 +7298,89 @@ function renderSelfUpdateRuns(container, runs) {
                     <th style="padding:8px;">To</th>
                     <th style="padding:8px;">Status</th>
                     <th style="padding:8px;">Message</th>
-                    <th style="padding:8px;">Finished</th>
+                    <th style="padding:8px;">Finished</th>
+                </tr>
+            </thead>
+            <tbody>
+                ${rows}
+            </tbody>
+        </table>
+    `;
+}
+
+// ---------------------------------------------------------------------------
+// Agent Rollout Failures
+// ---------------------------------------------------------------------------
+
+let agentUpdateFailuresInitialized = false;
+
+async function loadAgentUpdateFailures() {
+    const container = document.getElementById('agent_update_failures_container');
+    if (!container) return;
+
+    if (!agentUpdateFailuresInitialized) {
+        agentUpdateFailuresInitialized = true;
+        const refreshBtn = document.getElementById('agent_update_failures_refresh_btn');
+        if (refreshBtn) {
+            refreshBtn.addEventListener('click', () => loadAgentUpdateFailures());
+        }
+    }
+
+    const countEl = document.getElementById('agent_update_failures_count');
+    const detail = document.getElementById('agent_update_failure_detail');
+    if (detail) {
+        detail.style.display = 'none';
+        detail.innerHTML = '';
+    }
+
+    try {
+        const resp = await fetchJSON('/api/v1/agents/update/failures');
+        const clusters = Array.isArray(resp.clusters) ? resp.clusters : [];
+        if (countEl) {
+            countEl.textContent = resp.failures ? `${resp.failures} failed run${resp.failures === 1 ? '' : 's'}` : '';
+        }
+        renderAgentUpdateFailures(container, clusters);
+    } catch (err) {
+        container.innerHTML = `<div style="color:var(--danger);">Failed to load rollout failures: ${escapeHtml(err.message || String(err))}</div>`;
+    }
+}
+
+function renderAgentUpdateFailures(container, clusters) {
+    if (!clusters || clusters.length === 0) {
+        container.innerHTML = '<div class="muted-text">No failed agent updates in the last 30 days.</div>';
+        return;
+    }
+
+    const cell = 'padding:8px;border-bottom:1px solid var(--border);';
+    const rows = clusters.map(c => {
+        const platforms = Object.entries(c.platforms || {})
+            .map(([p, n]) => `${escapeHtml(p)} (${n})`)
+            .join(', ') || '—';
+        const agents = (c.agent_ids || []).join(', ');
+        const badgeColor = c.agent_count > 1 ? 'var(--danger)' : 'var(--warning)';
+        return `
+        <tr>
+            <td style="${cell}"><span style="display:inline-block;padding:2px 8px;border-radius:4px;font-size:11px;font-weight:600;background:${badgeColor}20;color:${badgeColor};" title="${escapeHtml(agents)}">${c.agent_count} agent${c.agent_count === 1 ? '' : 's'}</span></td>
+            <td style="${cell}">${escapeHtml(c.target_version || '—')}</td>
+            <td style="${cell}">${escapeHtml(c.error_code || '—')}</td>
+            <td style="${cell}max-width:360px;overflow:hidden;text-overflow:ellipsis;white-space:nowrap;" title="${escapeHtml(c.sample_message || '')}">${escapeHtml(c.sample_message || c.signature || '—')}</td>
+            <td style="${cell}">${platforms}</td>
+            <td style="${cell}">${c.last_seen ? new Date(c.last_seen).toLocaleString() : '—'}</td>
+            <td style="${cell}"><button class="ghost-btn" data-update-run-id="${c.sample_run_id}">Diagnostics</button></td>
+        </tr>`;
+    }).join('');
+
+    container.innerHTML = `
+        <table style="width:100%;border-collapse:collapse;font-size:13px;">
+            <thead>
+                <tr style="text-align:left;color:var(--muted);border-bottom:2px solid var(--border);">
+                    <th style="padding:8px;">Affected</th>
+                    <th style="padding:8px;">Target</th>
+                    <th style="padding:8px;">Error</th>
+                    <th style="padding:8px;">Message</th>
+                    <th style="padding:8px;">Platforms</th>
+                    <th style="padding:8px;">Last Seen</th>
+                    <th style="padding:8px;"></th>
                 </tr>
             </thead>
             <tbody>
@@ -6111,6 +7388,60 @@ function renderSelfUpdateRuns(container, runs) {
             </tbody>
         </table>
     `;
+
+    container.querySelectorAll('button[data-update-run-id]').forEach(btn => {
+        btn.addEventListener('click', () => showAgentUpdateRunDiagnostics(btn.dataset.updateRunId));
+    });
+}
+
+async function showAgentUpdateRunDiagnostics(runId) {
+    const detail = document.getElementById('agent_update_failure_detail');
+    if (!detail) return;
+    detail.style.display = '';
+    detail.innerHTML = '<div class="muted-text">Loading diagnostics…</div>';
+
+    try {
+        const run = await fetchJSON(`/api/v1/agents/update/runs?id=${encodeURIComponent(runId)}`);
+        const d = run.diagnostics;
+        const pre = 'margin:4px 0 12px;padding:8px;max-height:300px;overflow:auto;background:var(--bg);border:1px solid var(--border);border-radius:4px;font-size:12px;white-space:pre-wrap;';
+        let html = `
+            <div style="display:flex;justify-content:space-between;align-items:center;gap:8px;">
+                <div style="font-weight:600;">Agent ${escapeHtml(run.agent_id)} · ${escapeHtml(run.current_version || '?')} → ${escapeHtml(run.target_version || '?')}</div>
+                <button class="ghost-btn" id="agent_update_failure_detail_close">Close</button>
+            </div>
+            <div style="color:var(--danger);font-size:13px;margin:4px 0 8px;">${escapeHtml(run.error_code || '')} ${escapeHtml(run.error_message || '')}</div>
+        `;
+        if (!d) {
+            html += '<div class="muted-text">The agent did not send diagnostics for this failure (agents before this release do not collect them).</div>';
+        } else {
+            const env = [
+                ['OS / Arch', `${d.os || '?'}/${d.arch || '?'}`],
+                ['Install method', d.install_method],
+                ['Service', d.is_service ? 'yes' : 'no'],
+                ['Channel', d.channel],
+                ['Hostname', d.hostname],
+                ['Binary', d.binary_path],
+                ['Free disk', d.disk_free_mb ? `${d.disk_free_mb} MB` : ''],
+                ['Go', d.go_version],
+                ...Object.entries(d.environment || {})
+            ].filter(([, v]) => v);
+            html += `<div style="display:flex;flex-wrap:wrap;gap:4px 16px;font-size:12px;color:var(--muted);">${env.map(([k, v]) => `<span><strong>${escapeHtml(k)}:</strong> ${escapeHtml(String(v))}</span>`).join('')}</div>`;
+            html += `<div style="font-weight:600;font-size:12px;margin-top:8px;">Autoupdate log</div><pre style="${pre}">${escapeHtml((d.log_tail || []).join('\n') || '(empty)')}</pre>`;
+            if (d.installer_log) {
+                html += `<div style="font-weight:600;font-size:12px;">Installer log (tail)</div><pre style="${pre}">${escapeHtml(d.installer_log)}</pre>`;
+            }
+        }
+        detail.innerHTML = html;
+        const closeBtn = document.getElementById('agent_update_failure_detail_close');
+        if (closeBtn) {
+            closeBtn.addEventListener('click', () => {
+                detail.style.display = 'none';
+                detail.innerHTML = '';
+            });
+        }
+    } catch (err) {
+        detail.innerHTML = `<div style="color:var(--danger);">Failed to load diagnostics: ${escapeHtml(err.message || String(err))}</div>`;
+    }
 }
 
 // ---------------------------------------------------------------------------
@@ -6267,6 +7598,42 @@ async function triggerReleasesSync() {
     }
 }
 
+// importReleaseBundle uploads an offline release bundle so air-gapped servers
+// can serve agent updates without reaching GitHub.
+async function importReleaseBundle(file, btn) {
+    const label = btn ? btn.textContent : '';
+    if (btn) {
+        btn.disabled = true;
+        btn.textContent = 'Importing...';
+    }
+    try {
+        const form = new FormData();
+        form.append('bundle', file, file.name);
+        const resp = await fetch('/api/v1/releases/import', {
+            method: 'POST',
+            body: form,
+            credentials: 'same-origin'
+        });
+        const text = await resp.text();
+        let data = {};
+        try { data = JSON.parse(text); } catch (e) { data = { error: text.trim() }; }
+        if (!resp.ok) {
+            throw new Error(data.error || `HTTP ${resp.status}`);
+        }
+        const imported = (data.imported || []).length;
+        const skipped = (data.skipped || []).length;
+        window.__pm_shared.showToast(`Imported ${imported} artifact${imported === 1 ? '' : 's'}` + (skipped ? ` (${skipped} skipped)` : ''), skipped ? 'info' : 'success');
+        loadReleaseArtifacts();
+    } catch (err) {
+        window.__pm_shared.showToast('Bundle import failed: ' + (err.message || err), 'error');
+    } finally {
+        if (btn) {
+            btn.disabled = false;
+            btn.textContent = label;
+        }
+    }
+}
+
 // ---------------------------------------------------------------------------
 // Release Artifacts Loading (shared between Fleet and Server tabs)
 // ---------------------------------------------------------------------------
@@ -6282,6 +7649,17 @@ function initReleaseArtifactsUI() {
         syncBtn.addEventListener('click', () => triggerReleasesSync());
     }
 
+    const importBtn = document.getElementById('releases_import_btn');
+    const importFile = document.getElementById('releases_import_file');
+    if (importBtn && importFile) {
+        importBtn.addEventListener('click', () => importFile.click());
+        importFile.addEventListener('change', () => {
+            const file = importFile.files && importFile.files[0];
+            importFile.value = '';
+            if (file) importReleaseBundle(file, importBtn);
+        });
+    }
+
     // Initialize collapsible header
     const header = document.getElementById('releases_artifacts_header');
     const container = document.getElementById('releases_artifacts_container');
@@ -7313,6 +8691,7 @@ function openTenantModal(tenant) {
     }
     const safe = (key) => (tenant && tenant[key]) ? tenant[key] : '';
     document.getElementById('tenant_name').value = safe('name');
+    document.getElementById('tenant_kind').value = safe('kind') || 'msp_customer';
     document.getElementById('tenant_login_domain').value = safe('login_domain');
     document.getElementById('tenant_contact_name').value = safe('contact_name');
     document.getElementById('tenant_contact_email').value = safe('contact_email');
@@ -7439,6 +8818,7 @@ function closeTenantModal() {
 function collectTenantFormData() {
     return {
         name: (document.getElementById('tenant_name').value || '').trim(),
+        kind: document.getElementById('tenant_kind').value || '',
         description: (document.getElementById('tenant_description').value || '').trim(),
         contact_name: (document.getElementById('tenant_contact_name').value || '').trim(),
         contact_email: (document.getElementById('tenant_contact_email').value || '').trim(),
@@ -7893,6 +9273,10 @@ function renderUsers(list) {
                 <span class="users-stat-value">${list.filter(u => u.role === 'admin').length}</span>
                 <span class="users-stat-label">Admins</span>
             </div>
+            <div class="users-stat">
+                <span class="users-stat-value">${list.filter(u => u.role === 'tenant_admin').length}</span>
+                <span class="users-stat-label">Tenant Admins</span>
+            </div>
             <div class="users-stat">
                 <span class="users-stat-value">${list.filter(u => u.role === 'operator').length}</span>
                 <span class="users-stat-label">Operators</span>
@@ -7901,6 +9285,10 @@ function renderUsers(list) {
                 <span class="users-stat-value">${list.filter(u => u.role === 'viewer').length}</span>
                 <span class="users-stat-label">Viewers</span>
             </div>
+            <div class="users-stat">
+                <span class="users-stat-value">${list.filter(u => u.role === 'auditor').length}</span>
+                <span class="users-stat-label">Auditors</span>
+            </div>
         </div>
         <div class="panel">
             <div class="table-wrapper">
@@ -7966,10 +9354,105 @@ async function loadUserSessions(userId, username) {
     }
 }
 
+// Load the signed-in user's own sessions (no admin permission needed)
+async function loadMySessions() {
+    try {
+        const r = await fetch('/api/v1/auth/sessions');
+        if (!r.ok) throw new Error(await r.text());
+        const sessions = await r.json();
+        showSessionsModal(sessions, currentUser?.username, currentUser?.id, 'self');
+    } catch (err) {
+        window.__pm_shared.showAlert('Failed to load sessions: ' + (err.message || err), 'Error', true, false);
+    }
+}
+
+// Edit the signed-in user's own alert notification preferences
+async function showMyNotificationsModal() {
+    const modal = document.getElementById('my_notifications_modal');
+    if (!modal) return;
+    let prefs;
+    try {
+        const r = await fetch('/api/v1/users/me/notification-preferences');
+        if (!r.ok) throw new Error(await r.text());
+        prefs = await r.json();
+    } catch (err) {
+        window.__pm_shared.showToast('Failed to load notification preferences: ' + (err.message || err), 'error');
+        return;
+    }
+
+    const el = id => document.getElementById(id);
+    const hour = el('my_notifications_hour');
+    if (hour && hour.options.length === 0) {
+        for (let h = 0; h < 24; h++) {
+            const opt = document.createElement('option');
+            opt.value = String(h);
+            opt.textContent = String(h).padStart(2, '0') + ':00';
+            hour.appendChild(opt);
+        }
+    }
+    el('my_notifications_enabled').checked = !!prefs.enabled;
+    el('my_notifications_severity').value = prefs.min_severity || 'warning';
+    el('my_notifications_types').value = (prefs.alert_types || []).join(', ');
+    el('my_notifications_email_enabled').checked = !!prefs.email_enabled;
+    el('my_notifications_email').value = prefs.email_address || '';
+    el('my_notifications_email').placeholder = prefs.account_email || 'you@example.com';
+    el('my_notifications_webhook').value = prefs.webhook_url || '';
+    el('my_notifications_frequency').value = prefs.frequency || 'immediate';
+    hour.value = String(prefs.digest_hour ?? 8);
+    const syncHour = () => {
+        el('my_notifications_hour_label').style.display = el('my_notifications_frequency').value === 'daily' ? '' : 'none';
+    };
+    el('my_notifications_frequency').onchange = syncHour;
+    syncHour();
+
+    const closeModal = () => modal.style.display = 'none';
+    el('my_notifications_close_x').onclick = closeModal;
+    el('my_notifications_cancel').onclick = closeModal;
+    el('my_notifications_save').onclick = async () => {
+        const body = {
+            enabled: el('my_notifications_enabled').checked,
+            min_severity: el('my_notifications_severity').value,
+            alert_types: el('my_notifications_types').value.split(',').map(t => t.trim()).filter(Boolean),
+            email_enabled: el('my_notifications_email_enabled').checked,
+            email_address: el('my_notifications_email').value.trim(),
+            webhook_url: el('my_notifications_webhook').value.trim(),
+            frequency: el('my_notifications_frequency').value,
+            digest_hour: Number(hour.value),
+        };
+        try {
+            const r = await fetch('/api/v1/users/me/notification-preferences', {
+                method: 'PUT',
+                headers: { 'Content-Type': 'application/json' },
+                body: JSON.stringify(body),
+            });
+            if (!r.ok) throw new Error((await r.text()).trim() || `HTTP ${r.status}`);
+            window.__pm_shared.showToast('Notification preferences saved', 'success');
+            closeModal();
+        } catch (err) {
+            window.__pm_shared.showToast('Failed to save: ' + (err.message || err), 'error');
+        }
+    };
+    modal.style.display = 'flex';
+}
+
+// Summarize a user agent string as "Browser on OS"
+function describeUserAgent(ua) {
+    if (!ua) return '';
+    const browsers = [[/Edg\//, 'Edge'], [/OPR\//, 'Opera'], [/Firefox\//, 'Firefox'], [/Chrome\//, 'Chrome'], [/Safari\//, 'Safari'], [/curl\//, 'curl']];
+    const systems = [[/Windows/, 'Windows'], [/Android/, 'Android'], [/iPhone|iPad/, 'iOS'], [/Mac OS X|Macintosh/, 'macOS'], [/Linux/, 'Linux']];
+    const browser = (browsers.find(([re]) => re.test(ua)) || [null, ''])[1];
+    const os = (systems.find(([re]) => re.test(ua)) || [null, ''])[1];
+    if (browser && os) return `${browser} on ${os}`;
+    return browser || os || ua.slice(0, 40);
+}
+
 // Sessions modal sort state
 let sessionsSort = { key: 'created_at', dir: 'desc' };
 
-function showSessionsModal(sessions, username, userId) {
+// mode 'self' manages the caller's own sessions; otherwise admin endpoints are used
+function showSessionsModal(sessions, username, userId, mode) {
+    const self = mode === 'self';
+    const reload = () => self ? loadMySessions() : loadUserSessions(userId, username);
     // Remove existing modal if present (prevents stacking)
     if (activeSessionsModal && activeSessionsModal.parentNode) {
         activeSessionsModal.parentNode.removeChild(activeSessionsModal);
@@ -8004,7 +9487,7 @@ function showSessionsModal(sessions, username, userId) {
         <div class="sessions-modal-header">
             <div class="sessions-modal-title">
                 <svg width="20" height="20" viewBox="0 0 16 16" fill="currentColor"><path d="M8 8a3 3 0 1 0 0-6 3 3 0 0 0 0 6zm2-3a2 2 0 1 1-4 0 2 2 0 0 1 4 0zm4 8c0 1-1 1-1 1H3s-1 0-1-1 1-4 6-4 6 3 6 4zm-1-.004c-.001-.246-.154-.986-.832-1.664C11.516 10.68 10.289 10 8 10c-2.29 0-3.516.68-4.168 1.332-.678.678-.83 1.418-.832 1.664h10z"/></svg>
-                Sessions for ${escapeHtml(username || 'user')}
+                ${self ? 'Your Sessions' : `Sessions for ${escapeHtml(username || 'user')}`}
             </div>
             <button class="sessions-modal-close" data-action="close">&times;</button>
         </div>
@@ -8019,7 +9502,10 @@ function showSessionsModal(sessions, username, userId) {
                 <table class="sessions-table">
                     <thead>
                         <tr>
+                            <th>Client</th>
+                            <th data-sort-key="ip_address" class="sortable">IP Address${sortIcon('ip_address')}</th>
                             <th data-sort-key="created_at" class="sortable">Created${sortIcon('created_at')}</th>
+                            <th data-sort-key="last_seen_at" class="sortable">Last Active${sortIcon('last_seen_at')}</th>
                             <th data-sort-key="expires_at" class="sortable">Expires${sortIcon('expires_at')}</th>
                             <th>Status</th>
                             <th class="actions-col">Actions</th>
@@ -8035,12 +9521,18 @@ function showSessionsModal(sessions, username, userId) {
             const expiresStr = expires ? formatRelativeTime(expires) : '—';
             const createdFull = created ? created.toLocaleString() : '';
             const expiresFull = expires ? expires.toLocaleString() : '';
+            const lastSeen = s.last_seen_at ? new Date(s.last_seen_at) : null;
+            const lastSeenStr = lastSeen ? formatRelativeTime(lastSeen) : '—';
+            const lastSeenFull = lastSeen ? lastSeen.toLocaleString() : '';
             const isCurrent = s.token === currentTokenHash;
             const isExpired = expires && expires < new Date();
 
             content += `
                 <tr class="${isCurrent ? 'current-session' : ''} ${isExpired ? 'expired-session' : ''}">
+                    <td title="${escapeHtml(s.user_agent || '')}">${escapeHtml(describeUserAgent(s.user_agent) || '—')}</td>
+                    <td>${escapeHtml(s.ip_address || '—')}</td>
                     <td title="${escapeHtml(createdFull)}">${createdStr}</td>
+                    <td title="${escapeHtml(lastSeenFull)}">${lastSeenStr}</td>
                     <td title="${escapeHtml(expiresFull)}">${expiresStr}</td>
                     <td>
                         ${isCurrent ? '<span class="session-badge current">Current</span>' : ''}
@@ -8122,7 +9614,7 @@ function showSessionsModal(sessions, username, userId) {
                 sessionsSort.key = key;
                 sessionsSort.dir = 'desc';
             }
-            showSessionsModal(sessions, username, userId);
+            showSessionsModal(sessions, username, userId, mode);
         });
     });
 
@@ -8132,10 +9624,11 @@ function showSessionsModal(sessions, username, userId) {
             const key = b.getAttribute('data-key');
             if (!await window.__pm_shared.showConfirm('Revoke this session?', 'Confirm')) return;
             try {
-                const r = await fetch('/api/v1/sessions/' + encodeURIComponent(key), { method: 'DELETE' });
+                const base = self ? '/api/v1/auth/sessions/' : '/api/v1/sessions/';
+                const r = await fetch(base + encodeURIComponent(key), { method: 'DELETE' });
                 if (!r.ok) throw new Error(await r.text());
                 window.__pm_shared.showToast('Session revoked', 'success');
-                await loadUserSessions(userId, username);
+                await reload();
             } catch (err) {
                 window.__pm_shared.showAlert('Failed to revoke session: ' + (err.message || err), 'Error', true, false);
             }
@@ -8152,11 +9645,16 @@ function showSessionsModal(sessions, username, userId) {
             }
             if (!await window.__pm_shared.showConfirm(`End ${otherSessions.length} other session${otherSessions.length !== 1 ? 's' : ''}?`, 'Confirm')) return;
             try {
-                for (const s of otherSessions) {
-                    await fetch('/api/v1/sessions/' + encodeURIComponent(s.token), { method: 'DELETE' });
-                }
+                const r = self
+                    ? await fetch('/api/v1/auth/sessions/others', { method: 'DELETE' })
+                    : await fetch('/api/v1/sessions/revoke', {
+                        method: 'POST',
+                        headers: { 'Content-Type': 'application/json' },
+                        body: JSON.stringify({ keys: otherSessions.map(s => s.token) })
+                    });
+                if (!r.ok) throw new Error(await r.text());
                 window.__pm_shared.showToast('Other sessions ended', 'success');
-                await loadUserSessions(userId, username);
+                await reload();
             } catch (err) {
                 window.__pm_shared.showAlert('Failed to revoke sessions: ' + (err.message || err), 'Error', true, false);
             }
@@ -8172,14 +9670,25 @@ function showSessionsModal(sessions, username, userId) {
                 : `End all ${sortedSessions.length} session${sortedSessions.length !== 1 ? 's' : ''}?`;
             if (!await window.__pm_shared.showConfirm(msg, 'Confirm')) return;
             try {
-                for (const s of sortedSessions) {
-                    await fetch('/api/v1/sessions/' + encodeURIComponent(s.token), { method: 'DELETE' });
+                let r;
+                if (self) {
+                    r = await fetch('/api/v1/auth/sessions/others', { method: 'DELETE' });
+                    if (r.ok && currentTokenHash) {
+                        r = await fetch('/api/v1/auth/sessions/' + encodeURIComponent(currentTokenHash), { method: 'DELETE' });
+                    }
+                } else {
+                    r = await fetch('/api/v1/sessions/revoke', {
+                        method: 'POST',
+                        headers: { 'Content-Type': 'application/json' },
+                        body: JSON.stringify({ user_id: Number(userId) })
+                    });
                 }
+                if (!r.ok) throw new Error(await r.text());
                 window.__pm_shared.showToast('All sessions ended', 'success');
                 if (willLogOut) {
                     window.location.href = '/login';
                 } else {
-                    await loadUserSessions(userId, username);
+                    await reload();
                 }
             } catch (err) {
                 window.__pm_shared.showAlert('Failed to revoke sessions: ' + (err.message || err), 'Error', true, false);
@@ -9352,7 +10861,7 @@ function renderTokenModal(tenantID, tokens) {
     window.__pm_shared.showAlert(html, 'Tokens for tenant: ' + escapeHtml(tenantID), false, false, true);
     showInputModal('Revoke token', 'Enter the token ID to revoke (leave empty to cancel)', '').then(id => {
         if (!id) return;
-        revokeToken(id.trim()).then(() => {
+        revokeToken(id.trim(), tenantID).then(() => {
             window.__pm_shared.showToast('Revoked ' + id.trim(), 'success');
             showTokensList(tenantID);
         }).catch(err => {
@@ -9361,8 +10870,8 @@ function renderTokenModal(tenantID, tokens) {
     }).catch(() => { });
 }
 
-async function revokeToken(id) {
-    const r = await fetch('/api/v1/join-token/revoke', { method: 'POST', headers: { 'content-type': 'application/json' }, body: JSON.stringify({ id }) });
+async function revokeToken(id, tenantID) {
+    const r = await fetch('/api/v1/join-token/revoke', { method: 'POST', headers: { 'content-type': 'application/json' }, body: JSON.stringify({ id, tenant_id: tenantID }) });
     if (!r.ok) throw new Error(await r.text());
     return r.json();
 }
@@ -10879,6 +12388,18 @@ async function viewAgentDetails(agentId) {
     }
 }
 
+const AGENT_RUNTIME_ENVIRONMENT_LABELS = {
+    container: 'Container',
+    systemd: 'systemd service',
+    windows_service: 'Windows service',
+    service: 'Service',
+    interactive: 'Interactive',
+};
+
+function formatAgentRuntimeEnvironment(env) {
+    return escapeHtml(AGENT_RUNTIME_ENVIRONMENT_LABELS[env] || env);
+}
+
 function renderAgentDetailsModal(agent) {
     const title = document.getElementById('agent_details_title');
     const body = document.getElementById('agent_details_body');
@@ -10971,6 +12492,12 @@ function renderAgentDetailsModal(agent) {
                         <span class="device-card-value">${agent.architecture}</span>
                     </div>
                     ` : ''}
+                    ${agent.runtime_environment ? `
+                    <div class="device-card-row">
+                        <span class="device-card-label">Runs As</span>
+                        <span class="device-card-value">${formatAgentRuntimeEnvironment(agent.runtime_environment)}</span>
+                    </div>
+                    ` : ''}
                     ${agent.num_cpu ? `
                     <div class="device-card-row">
                         <span class="device-card-label">CPUs</span>
@@ -11303,7 +12830,10 @@ function _attachAgentUpdateHandler(agent) {
                         throw new Error(txt || 'Request failed');
                     }
                     const data = await res.json();
-                    if (data.success) {
+                    if (data.approval_required) {
+                        window.__pm_shared.showToast(data.message, 'info');
+                        setStatus(data.message, 'info');
+                    } else if (data.success) {
                         const summary = data.message || 'Forced reinstall triggered';
                         window.__pm_shared.showToast(summary, 'success');
                         setStatus(`${summary} at ${new Date().toLocaleTimeString()}`, 'success');
@@ -11694,6 +13224,11 @@ async function deleteAgent(agentId, displayName) {
             window.__pm_shared.error('Delete failed:', errorText);
             throw new Error(`HTTP ${response.status}: ${errorText}`);
         }
+        if (response.status === 202) {
+            const held = await response.json();
+            window.__pm_shared.showToast(held.message || 'Deletion is waiting for approval', 'info');
+            return;
+        }
 
         const result = await response.json();
         window.__pm_shared.log('Delete successful:', result);
@@ -11761,6 +13296,47 @@ try {
     window.__pm_shared.restartAgent = restartAgent;
 } catch (e) { console.warn('Failed to expose restartAgent to shared namespace', e); }
 
+// ====== Rotate Agent Tokens ======
+// Forces the agents to receive a new token on their next heartbeat; the old
+// token stops working shortly after. Used after a suspected token leak.
+async function rotateAgentTokens(agentIds, displayName) {
+    const ids = (agentIds || []).filter(Boolean);
+    if (!ids.length) {
+        return;
+    }
+    const subject = ids.length === 1 ? `agent "${displayName || ids[0]}"` : `${ids.length} agents`;
+    const confirmed = await window.__pm_shared.showConfirm(
+        `Issue a new token to ${subject}?\n\nThe new token is delivered on the next heartbeat and the current token stops working a few minutes later. Offline agents keep the request until they reconnect.`,
+        'Rotate Agent Token',
+        { confirmText: 'Rotate', confirmClass: 'btn-warning' }
+    );
+    if (!confirmed) {
+        return;
+    }
+
+    try {
+        const response = await fetch('/api/v1/agents/tokens/rotate', {
+            method: 'POST',
+            headers: { 'Content-Type': 'application/json' },
+            body: JSON.stringify({ agent_ids: ids })
+        });
+        if (!response.ok) {
+            const errorText = await response.text();
+            throw new Error(`HTTP ${response.status}: ${errorText}`);
+        }
+        const result = await response.json();
+        window.__pm_shared.showToast(`Token rotation requested for ${result.requested} agent(s)`, 'success');
+    } catch (error) {
+        window.__pm_shared.error('Failed to rotate agent tokens:', error);
+        window.__pm_shared.showToast(`Failed to rotate agent tokens: ${error.message}`, 'error');
+    }
+}
+
+try {
+    window.__pm_shared.rotateAgentTokens = rotateAgentTokens;
+    window.__pm_shared.userCan = userCan;
+} catch (e) { console.warn('Failed to expose rotateAgentTokens to shared namespace', e); }
+
 // ====== Delete Device ======
 /**
  * Delete a device from the server (and optionally from the agent).
@@ -12044,6 +13620,21 @@ function initDevicesUI() {
         });
     }
 
+    const compareBtn = document.getElementById('devices_compare');
+    if (compareBtn) {
+        compareBtn.addEventListener('click', () => showDeviceCompareModal(devicesVM.filtered));
+    }
+
+    const exportBtn = document.getElementById('devices_export');
+    if (exportBtn) {
+        exportBtn.addEventListener('click', () => showDeviceExportModal());
+    }
+
+    const printLabelsBtn = document.getElementById('devices_print_labels');
+    if (printLabelsBtn) {
+        printLabelsBtn.addEventListener('click', () => showDeviceLabelsModal(devicesVM.filtered));
+    }
+
     const statusFilter = document.getElementById('devices_status_filter');
     if (statusFilter) {
         statusFilter.addEventListener('click', (event) => {
@@ -13307,7 +14898,7 @@ function buildDeviceSearchBlob(device, agentName, tenantLabel) {
 function classifyDeviceStatus(device) {
     const meta = { code: 'healthy', label: 'Healthy' };
     const severity = (device.status_severity || device.health_state || '').toLowerCase();
-    const composite = [device.status, device.state, device.health, device.connection_state].filter(Boolean).join(' ').toLowerCase();
+    const composite = [device.status_state, device.status, device.state, device.health, device.connection_state].filter(Boolean).join(' ').toLowerCase();
     if (composite.includes('jam')) {
         return { code: 'jam', label: 'Paper Jam' };
     }
@@ -13317,6 +14908,9 @@ function classifyDeviceStatus(device) {
     if (severity.includes('warn') || composite.includes('warn') || composite.includes('degraded')) {
         return { code: 'warning', label: 'Warning' };
     }
+    if (composite.includes('maintenance')) {
+        return { code: 'warning', label: 'Maintenance' };
+    }
     if (composite.includes('ready') || composite.includes('idle')) {
         return { code: 'healthy', label: 'Ready' };
     }
@@ -13622,7 +15216,7 @@ const DISCOVERY_SUBSECTIONS = [
     {
         key: 'passive_listeners',
         label: 'Passive Listeners',
-        fields: ['discovery.passive_discovery_enabled', 'discovery.auto_discover_live_mdns', 'discovery.auto_discover_live_wsd', 'discovery.auto_discover_live_ssdp', 'discovery.auto_discover_live_snmptrap', 'discovery.auto_discover_live_llmnr']
+        fields: ['discovery.passive_discovery_enabled', 'discovery.auto_discover_live_mdns', 'discovery.auto_discover_live_wsd', 'discovery.auto_discover_live_ssdp', 'discovery.auto_discover_live_snmptrap', 'discovery.snmp_trap_fallback_port', 'discovery.auto_discover_live_llmnr']
     },
     {
         key: 'metrics',
@@ -13714,7 +15308,11 @@ const settingsUIState = {
     updatePolicy: {
         global: createPolicyState(),
         tenant: createPolicyState()
-    }
+    },
+    // Resolved feature flags keyed by tenant ID ('' = server defaults)
+    featureFlags: {},
+    // Fleet-wide vendor detection rules ({ rules, loading, error, editingId })
+    detectionRules: {}
 };
 
 function resolveTenantId(record) {
@@ -14654,8 +16252,12 @@ function renderSettingsForm() {
         root.innerHTML = '<div class="muted-text">No server-managed settings are available in this build.</div>';
     }
     if (scope === 'global' || scope === 'tenant') {
+        refreshFeatureFlagsPanel();
         refreshPolicyPanel();
     }
+    if (scope === 'global') {
+        refreshDetectionRulesPanel();
+    }
 }
 
 /**
@@ -14941,6 +16543,337 @@ function renderSettingsFieldRow(field, value, scope, isSectionManaged = true, is
     return row;
 }
 
+function featureFlagsScopeKey() {
+    return settingsUIState.scope === 'tenant' ? (settingsUIState.selectedTenantId || '') : '';
+}
+
+function featureFlagsURL(tenantId) {
+    return tenantId
+        ? '/api/v1/settings/features/tenants/' + encodeURIComponent(tenantId)
+        : '/api/v1/settings/features';
+}
+
+function refreshFeatureFlagsPanel() {
+    const root = document.getElementById('settings_form_root');
+    if (!root) return;
+    const scope = settingsUIState.scope;
+    if (scope !== 'global' && scope !== 'tenant') return;
+    const panel = renderFeatureFlagsSection();
+    const existing = document.getElementById('feature_flags_section');
+    if (existing) {
+        existing.replaceWith(panel);
+    } else {
+        root.appendChild(panel);
+    }
+}
+
+async function loadFeatureFlags(tenantId) {
+    const entry = settingsUIState.featureFlags[tenantId] || {};
+    if (entry.loading) return;
+    entry.loading = true;
+    settingsUIState.featureFlags[tenantId] = entry;
+    try {
+        entry.snapshot = await fetchJSON(featureFlagsURL(tenantId));
+    } catch (err) {
+        entry.error = true;
+        reportSettingsError('Failed to load feature flags', err);
+    } finally {
+        entry.loading = false;
+    }
+    if (featureFlagsScopeKey() === tenantId) {
+        refreshFeatureFlagsPanel();
+    }
+}
+
+async function saveFeatureFlag(tenantId, key, value) {
+    try {
+        const snapshot = await fetchJSON(featureFlagsURL(tenantId), {
+            method: 'PUT',
+            headers: { 'Content-Type': 'application/json' },
+            body: JSON.stringify({ flags: { [key]: value } })
+        });
+        settingsUIState.featureFlags[tenantId] = { snapshot };
+        window.__pm_shared.showToast('Feature flag updated', 'success');
+    } catch (err) {
+        reportSettingsError('Failed to update feature flag', err);
+    }
+    if (featureFlagsScopeKey() === tenantId) {
+        refreshFeatureFlagsPanel();
+    }
+}
+
+function renderFeatureFlagsSection() {
+    const panel = document.createElement('div');
+    panel.className = 'settings-section-panel feature-flags';
+    panel.id = 'feature_flags_section';
+
+    const scope = settingsUIState.scope;
+    const header = document.createElement('div');
+    header.className = 'settings-section-header';
+    const description = scope === 'global'
+        ? 'Server-wide defaults for optional features. Customers inherit these unless overridden.'
+        : 'Turn optional features on or off for this customer only.';
+    header.innerHTML = `<h4>Feature Flags</h4><p>${escapeHtml(description)}</p>`;
+    panel.appendChild(header);
+
+    const body = document.createElement('div');
+    body.className = 'settings-field-list';
+    panel.appendChild(body);
+
+    const tenantId = featureFlagsScopeKey();
+    if (scope === 'tenant' && !tenantId) {
+        body.innerHTML = '<div class="muted-text">Select a customer to manage feature flags.</div>';
+        return panel;
+    }
+    const entry = settingsUIState.featureFlags[tenantId];
+    if (!entry || !entry.snapshot) {
+        body.innerHTML = entry && entry.error
+            ? '<div class="muted-text">Feature flags are unavailable.</div>'
+            : '<div class="muted-text">Loading feature flags…</div>';
+        if (!entry || (!entry.loading && !entry.error)) {
+            loadFeatureFlags(tenantId);
+        }
+        return panel;
+    }
+
+    const canEdit = userCan('feature_flags.write');
+    const inheritLabel = scope === 'global' ? 'Default' : 'Inherit';
+    (entry.snapshot.flags || []).forEach(flag => {
+        const row = document.createElement('div');
+        row.className = 'settings-field-row';
+        const label = document.createElement('div');
+        label.className = 'settings-field-label';
+        let sourceNote = '';
+        if (flag.override === undefined || flag.override === null) {
+            sourceNote = `${inheritLabel}: ${flag.enabled ? 'on' : 'off'}`;
+        } else if (flag.updated_by) {
+            sourceNote = `Set by ${flag.updated_by}`;
+        }
+        label.innerHTML = `<div class="field-title">${escapeHtml(flag.title || flag.key)}</div>`
+            + `<div class="field-description">${escapeHtml(flag.description || '')}`
+            + (sourceNote ? ` <span class="muted-text">(${escapeHtml(sourceNote)})</span>` : '')
+            + '</div>';
+
+        const control = document.createElement('div');
+        control.className = 'settings-field-control';
+        const select = document.createElement('select');
+        [['', inheritLabel], ['on', 'On'], ['off', 'Off']].forEach(([value, text]) => {
+            const opt = document.createElement('option');
+            opt.value = value;
+            opt.textContent = text;
+            select.appendChild(opt);
+        });
+        if (flag.override === true) {
+            select.value = 'on';
+        } else if (flag.override === false) {
+            select.value = 'off';
+        } else {
+            select.value = '';
+        }
+        select.disabled = !canEdit;
+        select.addEventListener('change', () => {
+            select.disabled = true;
+            const value = select.value === '' ? null : select.value === 'on';
+            saveFeatureFlag(tenantId, flag.key, value);
+        });
+        control.appendChild(select);
+        row.appendChild(label);
+        row.appendChild(control);
+        body.appendChild(row);
+    });
+    return panel;
+}
+
+function refreshDetectionRulesPanel() {
+    const root = document.getElementById('settings_form_root');
+    if (!root || settingsUIState.scope !== 'global') return;
+    const panel = renderDetectionRulesSection();
+    const existing = document.getElementById('detection_rules_section');
+    if (existing) {
+        existing.replaceWith(panel);
+    } else {
+        root.appendChild(panel);
+    }
+}
+
+async function loadDetectionRules() {
+    const state = settingsUIState.detectionRules;
+    if (state.loading) return;
+    state.loading = true;
+    try {
+        state.rules = await fetchJSON('/api/v1/detection-rules');
+        state.error = false;
+    } catch (err) {
+        state.error = true;
+        reportSettingsError('Failed to load detection rules', err);
+    } finally {
+        state.loading = false;
+    }
+    refreshDetectionRulesPanel();
+}
+
+async function saveDetectionRule(id, payload) {
+    try {
+        await fetchJSON(id ? '/api/v1/detection-rules/' + id : '/api/v1/detection-rules', {
+            method: id ? 'PUT' : 'POST',
+            headers: { 'Content-Type': 'application/json' },
+            body: JSON.stringify(payload)
+        });
+        settingsUIState.detectionRules.editingId = null;
+        window.__pm_shared.showToast(id ? 'Detection rule updated' : 'Detection rule added', 'success');
+    } catch (err) {
+        reportSettingsError('Failed to save detection rule', err);
+        return;
+    }
+    await loadDetectionRules();
+}
+
+async function deleteDetectionRule(rule) {
+    const ok = await window.__pm_shared.showConfirm(
+        `Delete the detection rule for ${rule.manufacturer}? Agents fall back to built-in detection for these devices.`,
+        'Delete Detection Rule', true);
+    if (!ok) return;
+    try {
+        await fetchJSON('/api/v1/detection-rules/' + rule.id, { method: 'DELETE' });
+        window.__pm_shared.showToast('Detection rule deleted', 'success');
+    } catch (err) {
+        reportSettingsError('Failed to delete detection rule', err);
+        return;
+    }
+    await loadDetectionRules();
+}
+
+function detectionRuleForm(rule) {
+    const form = document.createElement('form');
+    form.className = 'detection-rule-form settings-field-list';
+    const r = rule || { enabled: true, priority: 0 };
+    const field = (name, title, value, placeholder) => `
+        <label class="field">
+            <span>${escapeHtml(title)}</span>
+            <input type="text" name="${name}" value="${escapeHtml(value || '')}" placeholder="${escapeHtml(placeholder)}">
+        </label>`;
+    form.innerHTML = field('manufacturer', 'Manufacturer', r.manufacturer, 'Acme')
+        + field('sys_object_id_prefix', 'sysObjectID prefix', r.sys_object_id_prefix, '1.3.6.1.4.1.55555')
+        + field('sys_descr_regex', 'sysDescr pattern', r.sys_descr_regex, 'acme (mfp|laser)')
+        + field('model_regex', 'Model pattern', r.model_regex, 'acme ([A-Z0-9-]+)')
+        + field('vendor_module', 'Vendor module', r.vendor_module, 'HP')
+        + field('description', 'Description', r.description, '')
+        + `<label class="field">
+            <span>Priority</span>
+            <input type="number" name="priority" value="${Number(r.priority) || 0}">
+        </label>
+        <label class="field checkbox-field">
+            <input type="checkbox" name="enabled" ${r.enabled ? 'checked' : ''}>
+            <span>Enabled</span>
+        </label>
+        <div class="form-actions">
+            <button type="submit" class="btn btn-primary">${rule ? 'Save Rule' : 'Add Rule'}</button>
+            ${rule ? '<button type="button" class="ghost-btn" data-action="cancel">Cancel</button>' : ''}
+        </div>`;
+    form.addEventListener('submit', (e) => {
+        e.preventDefault();
+        const data = new FormData(form);
+        const payload = {
+            manufacturer: data.get('manufacturer'),
+            sys_object_id_prefix: data.get('sys_object_id_prefix'),
+            sys_descr_regex: data.get('sys_descr_regex'),
+            model_regex: data.get('model_regex'),
+            vendor_module: data.get('vendor_module'),
+            description: data.get('description'),
+            priority: parseInt(data.get('priority'), 10) || 0,
+            enabled: form.querySelector('input[name="enabled"]').checked
+        };
+        saveDetectionRule(rule ? rule.id : null, payload);
+    });
+    const cancel = form.querySelector('[data-action="cancel"]');
+    if (cancel) {
+        cancel.addEventListener('click', () => {
+            settingsUIState.detectionRules.editingId = null;
+            refreshDetectionRulesPanel();
+        });
+    }
+    return form;
+}
+
+function renderDetectionRulesSection() {
+    const panel = document.createElement('div');
+    panel.className = 'settings-section-panel detection-rules';
+    panel.id = 'detection_rules_section';
+
+    const header = document.createElement('div');
+    header.className = 'settings-section-header';
+    header.innerHTML = '<h4>Vendor Detection Rules</h4>'
+        + '<p>Recognize new device families by sysObjectID prefix or sysDescr pattern. '
+        + 'Enabled rules are sent to every agent and checked before built-in detection.</p>';
+    panel.appendChild(header);
+
+    const body = document.createElement('div');
+    body.className = 'settings-field-list';
+    panel.appendChild(body);
+
+    const state = settingsUIState.detectionRules;
+    if (!state.rules) {
+        body.innerHTML = state.error
+            ? '<div class="muted-text">Detection rules are unavailable.</div>'
+            : '<div class="muted-text">Loading detection rules…</div>';
+        if (!state.loading && !state.error) {
+            loadDetectionRules();
+        }
+        return panel;
+    }
+
+    const canEdit = userCan('detection_rules.write');
+    if (!state.rules.length) {
+        body.innerHTML = '<div class="muted-text">No detection rules. Built-in detection is used for all devices.</div>';
+    } else {
+        const table = document.createElement('table');
+        table.className = 'simple-table detection-rules-table';
+        table.innerHTML = `<thead><tr>
+            <th>Manufacturer</th><th>sysObjectID prefix</th><th>sysDescr pattern</th>
+            <th>Model pattern</th><th>Priority</th><th>Status</th>${canEdit ? '<th></th>' : ''}
+        </tr></thead>`;
+        const tbody = document.createElement('tbody');
+        state.rules.forEach(rule => {
+            const tr = document.createElement('tr');
+            tr.innerHTML = `
+                <td title="${escapeHtml(rule.description || '')}">${escapeHtml(rule.manufacturer)}${rule.vendor_module ? ` <span class="muted-text">(${escapeHtml(rule.vendor_module)})</span>` : ''}</td>
+                <td><code>${escapeHtml(rule.sys_object_id_prefix || '—')}</code></td>
+                <td><code>${escapeHtml(rule.sys_descr_regex || '—')}</code></td>
+                <td><code>${escapeHtml(rule.model_regex || '—')}</code></td>
+                <td>${Number(rule.priority) || 0}</td>
+                <td>${rule.enabled ? 'Enabled' : '<span class="muted-text">Disabled</span>'}</td>`;
+            if (canEdit) {
+                const actions = document.createElement('td');
+                const edit = document.createElement('button');
+                edit.type = 'button';
+                edit.className = 'ghost-btn';
+                edit.textContent = 'Edit';
+                edit.addEventListener('click', () => {
+                    state.editingId = rule.id;
+                    refreshDetectionRulesPanel();
+                });
+                const del = document.createElement('button');
+                del.type = 'button';
+                del.className = 'ghost-btn';
+                del.textContent = 'Delete';
+                del.addEventListener('click', () => deleteDetectionRule(rule));
+                actions.appendChild(edit);
+                actions.appendChild(del);
+                tr.appendChild(actions);
+            }
+            tbody.appendChild(tr);
+        });
+        table.appendChild(tbody);
+        body.appendChild(table);
+    }
+
+    if (canEdit) {
+        const editing = state.rules.find(r => r.id === state.editingId);
+        body.appendChild(detectionRuleForm(editing || null));
+    }
+    return panel;
+}
+
 function refreshPolicyPanel() {
     const root = document.getElementById('settings_form_root');
     if (!root) return;
@@ -15027,10 +16960,72 @@ function renderUpdatePolicySection(root) {
     }
 
     appendPolicyInputs(body, scope, policyState, canEdit);
+    appendRolloutPreview(body, scope, policyState);
     panel.appendChild(body);
     root.appendChild(panel);
 }
 
+// Dry-run of the drafted policy: which agents would update, in which wave and
+// when, so restarts during business hours show up before saving.
+function appendRolloutPreview(body, scope, policyState) {
+    const wrapper = document.createElement('div');
+    wrapper.className = 'rollout-preview';
+    const button = document.createElement('button');
+    button.type = 'button';
+    button.textContent = 'Preview rollout';
+    const result = document.createElement('div');
+    result.className = 'rollout-preview-result';
+    button.addEventListener('click', async () => {
+        const tenantRef = scope === 'tenant' ? settingsUIState.selectedTenantId : 'global';
+        if (!tenantRef) return;
+        button.disabled = true;
+        result.innerHTML = '<div class="muted-text">Simulating rollout…</div>';
+        try {
+            const sim = await fetchJSON(`/api/v1/update-policies/${encodeURIComponent(tenantRef)}/simulate`, {
+                method: 'POST',
+                headers: { 'Content-Type': 'application/json' },
+                body: JSON.stringify({ policy: clonePolicySpec(policyState.policy) })
+            });
+            result.innerHTML = renderRolloutSimulation(sim);
+        } catch (err) {
+            result.innerHTML = `<div style="color:var(--danger);">Simulation failed: ${escapeHtml(err.message || String(err))}</div>`;
+        } finally {
+            button.disabled = false;
+        }
+    });
+    wrapper.appendChild(buildPolicyRow('Rollout preview', 'Shows which agents this policy would update, in which order, and when. Nothing is saved or sent to agents.', button));
+    wrapper.appendChild(result);
+    body.appendChild(wrapper);
+}
+
+function renderRolloutSimulation(sim) {
+    const fmt = ts => new Date(ts).toLocaleString();
+    let html = `<div class="rollout-preview-summary">${sim.targeted} of ${sim.agents_in_scope} agent(s) would update`;
+    if (sim.waves && sim.waves.length) {
+        html += ` in ${sim.waves.length} wave(s), starting ${escapeHtml(fmt(sim.waves[0].starts_at))}`;
+    }
+    html += '.</div>';
+    (sim.warnings || []).forEach(w => {
+        html += `<div class="rollout-preview-warning">⚠ ${escapeHtml(w)}</div>`;
+    });
+    if (sim.waves && sim.waves.length) {
+        html += '<table class="simple-table rollout-preview-table"><thead><tr><th>Ring</th><th>Starts</th><th>Agents</th></tr></thead><tbody>';
+        sim.waves.forEach(wave => {
+            const agents = wave.agents.map(a => `${escapeHtml(a.name || a.agent_id)} (${escapeHtml(a.current_version)} → ${escapeHtml(a.target_version)}${a.offline ? ', offline' : ''})`).join(', ');
+            html += `<tr><td>${wave.ring}</td><td>${escapeHtml(fmt(wave.starts_at))}</td><td>${agents}</td></tr>`;
+        });
+        html += '</tbody></table>';
+    }
+    if (sim.skipped && sim.skipped.length) {
+        html += `<details class="rollout-preview-skipped"><summary>${sim.skipped.length} agent(s) not updated</summary><ul>`;
+        sim.skipped.forEach(a => {
+            html += `<li>${escapeHtml(a.name || a.agent_id)}: ${escapeHtml(a.reason || '')}</li>`;
+        });
+        html += '</ul></details>';
+    }
+    return html;
+}
+
 function appendPolicyInputs(container, scope, policyState, canEdit) {
     const policy = policyState.policy || DEFAULT_UPDATE_POLICY_SPEC;
     const disabled = !canEdit || !policyState.enabled;
@@ -18948,9 +20943,8 @@ function updateTimeFilter(index) {
     if (maintenanceSaveBtn) maintenanceSaveBtn.addEventListener('click', saveMaintenanceWindow);
 
     // Scheduled Report Modal
+    // Save is wired by initScheduledReportModal
     wireModalClose('scheduled_report_modal', 'scheduled_report_modal_close_x', 'schedule_cancel');
-    const scheduleSaveBtn = document.getElementById('schedule_save');
-    if (scheduleSaveBtn) scheduleSaveBtn.addEventListener('click', saveScheduledReport);
 
     // Schedule frequency change handler - show/hide day fields
     const frequencySelect = document.getElementById('schedule_frequency');
@@ -51,6 +51,13 @@
             action: 'restart-agent',
             requiresWs: true
         },
+        {
+            id: 'rotate-token',
+            label: 'Rotate Token',
+            icon: '🔑',
+            action: 'rotate-agent-token',
+            requiresAction: 'agent_tokens.rotate'
+        },
         { divider: true },
         {
             id: 'copy-id',
@@ -158,6 +165,7 @@
             if (item.requiresUpdate && !context.hasUpdate) return;
             if (item.requiresAccess && !context.hasAccess) return;
             if (item.requiresSerial && !context.serial) return;
+            if (item.requiresAction && !(window.__pm_shared && typeof window.__pm_shared.userCan === 'function' && window.__pm_shared.userCan(item.requiresAction))) return;
 
             const menuItem = document.createElement('button');
             menuItem.className = 'pm-context-menu-item';
@@ -294,6 +302,18 @@
                 }
                 break;
 
+            case 'rotate-agent-token':
+                if (shared.rotateAgentTokens) {
+                    shared.rotateAgentTokens([context.agentId], context.agentName);
+                }
+                break;
+
+            case 'rotate-agent-tokens':
+                if (shared.rotateAgentTokens) {
+                    shared.rotateAgentTokens(context.selectedIds);
+                }
+                break;
+
             case 'delete-agent':
                 if (shared.deleteAgent) {
                     shared.deleteAgent(context.agentId, context.agentName);
@@ -511,6 +531,13 @@
                 disabled: true
             },
             { divider: true },
+            {
+                id: 'rotate-selected',
+                label: `Rotate ${context.selectedCount} Agent Tokens`,
+                icon: '🔑',
+                action: 'rotate-agent-tokens',
+                requiresAction: 'agent_tokens.rotate'
+            },
             {
                 id: 'delete-selected',
                 label: `Delete ${context.selectedCount} Agents`,
@@ -7,11 +7,20 @@
     }
 })(typeof self !== 'undefined' ? self : this, function () {
     const ROLE_PRIORITY = Object.freeze({
-        admin: 3,
+        admin: 4,
+        tenant_admin: 3,
         operator: 2,
+        auditor: 1,
         viewer: 1,
     });
 
+    // Actions a role may perform beyond what its rank grants. The server
+    // scopes these to the user's tenants.
+    const ROLE_EXTRA_ACTIONS = Object.freeze({
+        tenant_admin: Object.freeze(['users.read', 'users.write', 'join_tokens.read', 'join_tokens.write', 'tenants.read', 'audit.logs.read']),
+        auditor: Object.freeze(['audit.logs.read']),
+    });
+
     const ACTION_MIN_ROLE = Object.freeze({
         'tenants.read': 'admin',
         'tenants.write': 'admin',
@@ -30,6 +39,7 @@
         'agents.read': 'viewer',
         'agents.write': 'operator',
         'agents.delete': 'operator',
+        'agent_tokens.rotate': 'admin',
         'devices.read': 'viewer',
         'metrics.summary.read': 'viewer',
         'metrics.history.read': 'viewer',
@@ -44,13 +54,25 @@
         // Fleet settings (discovery, snmp, features) - tenant-scoped for operators+
         'settings.fleet.read': 'viewer',   // Viewers+ can read fleet settings (tenant-scoped)
         'settings.fleet.write': 'operator', // Operators+ can write fleet settings (tenant-scoped)
+        'settings.snmp_sweep.write': 'admin', // SNMP community sweep lists are admin-only
 
         // Alert settings (rules, channels, policies) - tenant-scoped for operators+
         'settings.alerts.read': 'viewer',   // Viewers+ can read alert config (tenant-scoped)
         'settings.alerts.write': 'operator', // Operators+ can write alert config (tenant-scoped)
 
+        // Feature flags (per-tenant entitlements) - admin-only writes
+        'feature_flags.read': 'viewer',
+        'feature_flags.write': 'admin',
+
+        // Vendor detection rules (fleet-wide) - admin-only writes
+        'detection_rules.read': 'viewer',
+        'detection_rules.write': 'admin',
+
         'logs.read': 'viewer',
         'audit.logs.read': 'admin',
+
+        // Report builder (custom reports) - tenant-scoped for operators+
+        'reports.build': 'operator',
     });
 
     function normalizeRole(role) {
@@ -86,6 +108,10 @@
     }
 
     function canPerformAction(role, action) {
+        const extras = ROLE_EXTRA_ACTIONS[normalizeRole(role)];
+        if (extras && extras.includes(action)) {
+            return true;
+        }
         const minRole = requiredRoleForAction(action);
         if (!minRole) {
             return false;
@@ -95,6 +121,7 @@
 
     return {
         ROLE_PRIORITY,
+        ROLE_EXTRA_ACTIONS,
         ACTION_MIN_ROLE,
         normalizeRole,
         roleRank,
@@ -0,0 +1,118 @@
+// Public read-only share view: loads the snapshot behind /share/<token>.
+(function(){
+  const doc = document;
+  const $ = (id) => doc.getElementById(id);
+  const token = decodeURIComponent(window.location.pathname.replace(/^\/share\//, '').split('/')[0] || '');
+  const messageEl = $('page_message');
+  const passwordForm = $('password_form');
+
+  function setMessage(text) {
+    messageEl.className = text ? 'error' : 'hidden';
+    messageEl.textContent = text || '';
+  }
+
+  function formatTime(value) {
+    if (!value) return '—';
+    const d = new Date(value);
+    if (isNaN(d.getTime()) || d.getFullYear() < 2000) return '—';
+    return d.toLocaleString();
+  }
+
+  function cell(row, text) {
+    const td = doc.createElement('td');
+    td.textContent = (text === undefined || text === null || text === '') ? '—' : String(text);
+    row.appendChild(td);
+  }
+
+  function supplies(levels) {
+    const names = Object.keys(levels || {}).sort();
+    return names.map((name) => name + ' ' + Math.round(Number(levels[name]) || 0) + '%').join(', ');
+  }
+
+  function renderDevices(devices) {
+    const body = $('devices_body');
+    body.textContent = '';
+    (devices || []).forEach((d) => {
+      const row = doc.createElement('tr');
+      cell(row, [d.manufacturer, d.model].filter(Boolean).join(' '));
+      cell(row, d.serial);
+      cell(row, d.asset_number);
+      cell(row, d.location);
+      cell(row, d.status_state);
+      cell(row, d.page_count ? d.page_count.toLocaleString() : '');
+      cell(row, supplies(d.toner_levels));
+      cell(row, formatTime(d.last_seen));
+      body.appendChild(row);
+    });
+    $('devices_view').classList.remove('hidden');
+  }
+
+  function renderReport(report) {
+    const view = $('report_view');
+    view.textContent = '';
+    const title = doc.createElement('p');
+    title.className = 'share-muted';
+    title.textContent = (report.name || 'Report') + ' · completed ' + formatTime(report.completed_at);
+    view.appendChild(title);
+    if (report.format === 'html') {
+      const frame = doc.createElement('iframe');
+      frame.className = 'share-report-frame';
+      frame.setAttribute('sandbox', '');
+      frame.srcdoc = report.content || '';
+      view.appendChild(frame);
+    } else {
+      const pre = doc.createElement('pre');
+      pre.className = 'share-report';
+      let content = report.content || '';
+      if (report.format === 'json') {
+        try { content = JSON.stringify(JSON.parse(content), null, 2); } catch (e) { /* show as-is */ }
+      }
+      pre.textContent = content;
+      view.appendChild(pre);
+    }
+    view.classList.remove('hidden');
+  }
+
+  async function load(password) {
+    setMessage('');
+    const headers = {};
+    if (password) headers['X-Share-Password'] = password;
+    let resp;
+    try {
+      resp = await fetch('/api/v1/share/' + encodeURIComponent(token), { headers, credentials: 'omit' });
+    } catch (e) {
+      setMessage('Could not reach the server.');
+      return;
+    }
+    if (resp.status === 401) {
+      $('share_subtitle').textContent = 'This shared view is password protected.';
+      passwordForm.classList.remove('hidden');
+      if (password) setMessage('Incorrect password.');
+      return;
+    }
+    if (!resp.ok) {
+      $('share_subtitle').textContent = '';
+      setMessage(resp.status === 410 ? 'This link has expired or was revoked.'
+        : resp.status === 429 ? 'Too many attempts. Try again later.'
+        : 'This link is not valid.');
+      return;
+    }
+    const data = await resp.json();
+    passwordForm.classList.add('hidden');
+    $('share_title').textContent = data.name || data.tenant || 'Shared view';
+    $('share_subtitle').textContent = (data.tenant ? data.tenant + ' · ' : '') +
+      'Snapshot ' + formatTime(data.generated_at) + ' · link expires ' + formatTime(data.expires_at);
+    if (data.kind === 'report' && data.report) {
+      renderReport(data.report);
+    } else {
+      renderDevices(data.devices);
+    }
+  }
+
+  passwordForm.addEventListener('submit', (ev) => {
+    ev.preventDefault();
+    load($('share_password').value);
+  });
+
+  load('');
+})();
@@ -0,0 +1,255 @@
+// Mobile technician view: scan a device, check its status and run remote actions.
+(function(){
+  const doc = document;
+  const $ = (id) => doc.getElementById(id);
+  const lookupForm = $('lookup_form');
+  const codeInput = $('lookup_code');
+  const scanBtn = $('scan_btn');
+  const scanner = $('scanner');
+  const video = $('scanner_video');
+  const messageEl = $('page_message');
+  const view = $('device_view');
+  const collectBtn = $('action_collect');
+  const webUILink = $('action_web_ui');
+
+  let current = null;
+  let stream = null;
+
+  function setMessage(text, kind) {
+    if (!text) {
+      messageEl.className = 'hidden';
+      messageEl.textContent = '';
+      return;
+    }
+    messageEl.className = kind || '';
+    messageEl.textContent = text;
+  }
+
+  function setText(id, value) {
+    $(id).textContent = (value === undefined || value === null || value === '') ? '—' : String(value);
+  }
+
+  function formatTime(value) {
+    if (!value) return '';
+    const d = new Date(value);
+    if (isNaN(d.getTime()) || d.getFullYear() < 2000) return '';
+    return d.toLocaleString();
+  }
+
+  function tonerColor(pct) {
+    if (pct <= 10) return 'var(--danger)';
+    if (pct <= 25) return '#b97300';
+    return 'var(--success)';
+  }
+
+  function renderToner(levels) {
+    const box = $('device_toner');
+    box.textContent = '';
+    const names = Object.keys(levels || {}).sort();
+    if (!names.length) {
+      const p = doc.createElement('p');
+      p.className = 'tech-muted';
+      p.textContent = 'No supply levels reported.';
+      box.appendChild(p);
+      return;
+    }
+    names.forEach((name) => {
+      const pct = Math.max(0, Math.min(100, Math.round(Number(levels[name]) || 0)));
+      const row = doc.createElement('div');
+      row.className = 'mini-consumable';
+      const label = doc.createElement('div');
+      label.className = 'mini-consumable-label';
+      label.style.width = '90px';
+      label.textContent = name;
+      const bar = doc.createElement('div');
+      bar.className = 'mini-consumable-bar';
+      const fill = doc.createElement('div');
+      fill.style.width = pct + '%';
+      fill.style.background = tonerColor(pct);
+      bar.appendChild(fill);
+      const val = doc.createElement('div');
+      val.className = 'mini-consumable-pct';
+      val.textContent = pct + '%';
+      row.append(label, bar, val);
+      box.appendChild(row);
+    });
+  }
+
+  function renderErrors(messages, alerts) {
+    const list = $('device_errors');
+    list.textContent = '';
+    (alerts || []).forEach((a) => {
+      const li = doc.createElement('li');
+      const strong = doc.createElement('strong');
+      strong.textContent = (a.severity || 'alert').toUpperCase() + ' ';
+      li.append(strong, doc.createTextNode(a.title || a.message || a.type));
+      const when = formatTime(a.triggered_at);
+      if (when) {
+        const small = doc.createElement('div');
+        small.className = 'tech-muted';
+        small.textContent = when;
+        li.appendChild(small);
+      }
+      list.appendChild(li);
+    });
+    (messages || []).forEach((m) => {
+      const li = doc.createElement('li');
+      li.textContent = m;
+      list.appendChild(li);
+    });
+    if (!list.children.length) {
+      const li = doc.createElement('li');
+      li.className = 'tech-muted';
+      li.textContent = 'No errors or open alerts.';
+      list.appendChild(li);
+    }
+  }
+
+  function renderScanPath(check) {
+    const el = $('device_scan_path');
+    if (!check) {
+      el.className = 'tech-muted';
+      el.textContent = 'No test scan received. Send a scan-to-email test to the PrintMaster mail sink.';
+      return;
+    }
+    el.className = '';
+    const when = formatTime(check.received_at);
+    if (check.verified) {
+      const files = check.attachment_count === 1 ? '1 attachment' : check.attachment_count + ' attachments';
+      el.textContent = 'Verified ' + when + ' (' + files + ')';
+    } else {
+      el.textContent = 'Test received ' + when + ' without an attachment; check the scan settings and try again.';
+    }
+  }
+
+  function render(data) {
+    const d = data.device || {};
+    current = data;
+    $('device_title').textContent = [d.manufacturer, d.model].filter(Boolean).join(' ') || 'Device';
+    $('device_subtitle').textContent = 'Serial ' + d.serial;
+    const badge = $('device_status');
+    const status = data.status || 'unknown';
+    badge.className = 'status-badge status-' + status;
+    badge.textContent = status;
+    setText('device_ip', d.ip);
+    setText('device_asset', d.asset_number);
+    setText('device_location', d.location);
+    setText('device_pages', d.page_count ? Number(d.page_count).toLocaleString() : '');
+    setText('device_last_seen', formatTime(d.last_seen));
+    const agent = data.agent || {};
+    setText('device_agent', (agent.name || agent.id || '') + (agent.id ? (agent.connected ? ' (online)' : ' (offline)') : ''));
+    renderToner(d.toner_levels);
+    renderErrors(d.status_messages, data.alerts);
+    renderScanPath(data.scan_path);
+
+    const actions = data.actions || {};
+    collectBtn.disabled = !actions.collect_metrics;
+    if (actions.web_ui) {
+      webUILink.href = '/api/v1/proxy/device/' + encodeURIComponent(d.serial) + '/';
+      webUILink.classList.remove('hidden');
+    } else {
+      webUILink.removeAttribute('href');
+      webUILink.classList.add('hidden');
+    }
+    view.classList.remove('hidden');
+  }
+
+  async function lookup(code) {
+    setMessage('', '');
+    try {
+      const res = await fetch('/api/v1/tech/lookup?code=' + encodeURIComponent(code), { credentials: 'same-origin' });
+      if (res.status === 401) {
+        window.location.href = '/login?redirect=' + encodeURIComponent('/tech?code=' + code);
+        return;
+      }
+      if (!res.ok) {
+        throw new Error(res.status === 404 ? 'No device matches "' + code + '".' : ((await res.text()) || ('HTTP ' + res.status)));
+      }
+      render(await res.json());
+      const url = new URL(window.location.href);
+      url.searchParams.set('code', code);
+      window.history.replaceState(null, '', url.toString());
+    } catch (err) {
+      view.classList.add('hidden');
+      current = null;
+      setMessage(err.message || String(err), 'error');
+    }
+  }
+
+  async function stopScan() {
+    if (stream) {
+      stream.getTracks().forEach((t) => t.stop());
+      stream = null;
+    }
+    scanner.classList.add('hidden');
+  }
+
+  async function startScan() {
+    try {
+      const detector = new window.BarcodeDetector();
+      stream = await navigator.mediaDevices.getUserMedia({ video: { facingMode: 'environment' } });
+      video.srcObject = stream;
+      scanner.classList.remove('hidden');
+      await video.play();
+      while (stream) {
+        const codes = await detector.detect(video);
+        if (codes.length && codes[0].rawValue) {
+          const value = codes[0].rawValue;
+          await stopScan();
+          codeInput.value = value;
+          lookup(value);
+          return;
+        }
+        await new Promise((resolve) => setTimeout(resolve, 250));
+      }
+    } catch (err) {
+      await stopScan();
+      setMessage('Camera scanning failed: ' + (err.message || err), 'error');
+    }
+  }
+
+  lookupForm.addEventListener('submit', (e) => {
+    e.preventDefault();
+    const code = codeInput.value.trim();
+    if (code) lookup(code);
+  });
+
+  if ('BarcodeDetector' in window && navigator.mediaDevices && navigator.mediaDevices.getUserMedia) {
+    scanBtn.classList.remove('hidden');
+    scanBtn.addEventListener('click', startScan);
+    $('scan_stop_btn').addEventListener('click', stopScan);
+  }
+
+  $('action_refresh').addEventListener('click', () => {
+    if (current) lookup(current.device.serial);
+  });
+
+  collectBtn.addEventListener('click', async () => {
+    if (!current) return;
+    const serial = current.device.serial;
+    collectBtn.disabled = true;
+    try {
+      const res = await fetch('/devices/metrics/collect', {
+        method: 'POST',
+        credentials: 'same-origin',
+        headers: { 'Content-Type': 'application/json' },
+        body: JSON.stringify({ serial: serial, ip: current.device.ip }),
+      });
+      if (!res.ok) {
+        throw new Error((await res.text()) || ('HTTP ' + res.status));
+      }
+      setMessage('Metrics collection started. Refreshing shortly…', 'success');
+      setTimeout(() => lookup(serial), 8000);
+    } catch (err) {
+      setMessage('Collect failed: ' + (err.message || err), 'error');
+    } finally {
+      collectBtn.disabled = false;
+    }
+  });
+
+  const initial = new URLSearchParams(window.location.search || '').get('code') || '';
+  if (initial) {
+    codeInput.value = initial;
+    lookup(initial);
+  }
+})();
@@ -345,7 +345,7 @@ func handleAgentWebSocket(w http.ResponseWriter, r *http.Request, serverStore st
 		// Handle different message types
 		switch msg.Type {
 		case wscommon.MessageTypeHeartbeat:
-			handleWSHeartbeat(conn, agent, msg, serverStore)
+			handleWSHeartbeat(conn, agent, &token, msg, serverStore)
 		case wscommon.MessageTypeProxyResponse:
 			handleWSProxyResponse(msg)
 		case wscommon.MessageTypeProxyStreamChunk:
@@ -365,8 +365,10 @@ func handleAgentWebSocket(w http.ResponseWriter, r *http.Request, serverStore st
 	}
 }
 
-// handleWSHeartbeat processes heartbeat messages received via WebSocket
-func handleWSHeartbeat(conn *wscommon.Conn, agent *storage.Agent, msg wscommon.Message, serverStore storage.Store) {
+// handleWSHeartbeat processes heartbeat messages received via WebSocket.
+// token is the credential the connection is using; it is advanced when the
+// heartbeat rotates the agent's token.
+func handleWSHeartbeat(conn *wscommon.Conn, agent *storage.Agent, token *string, msg wscommon.Message, serverStore storage.Store) {
 	// Extract optional device count from heartbeat data
 	deviceCount := 0
 	if dc, ok := msg.Data["device_count"].(float64); ok {
@@ -380,18 +382,19 @@ func handleWSHeartbeat(conn *wscommon.Conn, agent *storage.Agent, msg wscommon.M
 
 	// Build HeartbeatData from WebSocket message using shared logic
 	hbData := &storage.HeartbeatData{
-		Status:          status,
-		Version:         wsStringField(msg.Data, "version"),
-		ProtocolVersion: wsStringField(msg.Data, "protocol_version"),
-		Hostname:        wsStringField(msg.Data, "hostname"),
-		IP:              wsStringField(msg.Data, "ip"),
-		Platform:        wsStringField(msg.Data, "platform"),
-		OSVersion:       wsStringField(msg.Data, "os_version"),
-		GoVersion:       wsStringField(msg.Data, "go_version"),
-		Architecture:    wsStringField(msg.Data, "architecture"),
-		BuildType:       wsStringField(msg.Data, "build_type"),
-		GitCommit:       wsStringField(msg.Data, "git_commit"),
-		DeviceCount:     deviceCount,
+		Status:             status,
+		Version:            wsStringField(msg.Data, "version"),
+		ProtocolVersion:    wsStringField(msg.Data, "protocol_version"),
+		Hostname:           wsStringField(msg.Data, "hostname"),
+		IP:                 wsStringField(msg.Data, "ip"),
+		Platform:           wsStringField(msg.Data, "platform"),
+		OSVersion:          wsStringField(msg.Data, "os_version"),
+		GoVersion:          wsStringField(msg.Data, "go_version"),
+		Architecture:       wsStringField(msg.Data, "architecture"),
+		BuildType:          wsStringField(msg.Data, "build_type"),
+		GitCommit:          wsStringField(msg.Data, "git_commit"),
+		DeviceCount:        deviceCount,
+		RuntimeEnvironment: wsStringField(msg.Data, "runtime_environment"),
 	}
 
 	// Use timeout context to prevent database operations from hanging indefinitely
@@ -412,6 +415,11 @@ func handleWSHeartbeat(conn *wscommon.Conn, agent *storage.Agent, msg wscommon.M
 	}
 
 	logDebug("WebSocket heartbeat received", "agent_id", agent.AgentID)
+	checkWSAgentConfigDrift(ctx, conn, agent, msg.Data)
+	relayID, _ := msg.Data["relay_id"].(float64)
+	relayRTT, _ := msg.Data["relay_rtt_ms"].(float64)
+	recordAgentRelay(ctx, agent.AgentID, int64(relayID), int(relayRTT))
+	registerAgentSigningKey(ctx, agent, wsStringField(msg.Data, "meter_read_public_key"), "")
 
 	// Send pong response
 	pongMsg := wscommon.Message{
@@ -419,6 +427,27 @@ func handleWSHeartbeat(conn *wscommon.Conn, agent *storage.Agent, msg wscommon.M
 		Timestamp: time.Now(),
 	}
 
+	// The connection's agent record dates from connect time; rotation needs
+	// the current token state (including admin-forced rotation requests).
+	rotatedToken := ""
+	if rotation, _ := msg.Data["token_rotation"].(bool); rotation {
+		nonce, _ := msg.Data["token_rotation_nonce"].(string)
+		if current, err := serverStore.GetAgent(ctx, agent.AgentID); err != nil {
+			logWarn("Failed to load agent for token rotation", "agent_id", agent.AgentID, "error", err)
+		} else if renewal, err := renewAgentToken(ctx, serverStore, current, *token, nonce, true, ""); err != nil {
+			logWarn("Failed to rotate agent token", "agent_id", agent.AgentID, "error", err)
+		} else if renewal != nil {
+			pongMsg.Data = renewal.fields()
+			rotatedToken = renewal.Token
+		}
+	}
+	if relays := currentRelayAssignments(ctx); len(relays) > 0 {
+		if pongMsg.Data == nil {
+			pongMsg.Data = map[string]interface{}{}
+		}
+		pongMsg.Data["relays"] = relays
+	}
+
 	payload, err := json.Marshal(pongMsg)
 	if err != nil {
 		logError("Failed to marshal pong message", "error", err)
@@ -427,6 +456,10 @@ func handleWSHeartbeat(conn *wscommon.Conn, agent *storage.Agent, msg wscommon.M
 
 	if err := conn.WriteRaw(payload, 10*time.Second); err != nil {
 		logWarn("Failed to send pong to agent", "agent_id", agent.AgentID, "error", err)
+		return
+	}
+	if rotatedToken != "" {
+		*token = rotatedToken
 	}
 }
 
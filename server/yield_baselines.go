@@ -0,0 +1,84 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	authz "printmaster/server/authz"
+	"printmaster/server/storage"
+)
+
+// handleYieldBaselines returns (GET) or replaces (PUT) the cartridge
+// baseline library used by the toner yield report.
+func handleYieldBaselines(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	switch r.Method {
+	case http.MethodGet:
+		if !authorizeOrReject(w, r, authz.ActionSettingsServerRead, authz.ResourceRef{}) {
+			return
+		}
+		baselines, err := serverStore.ListYieldBaselines(ctx)
+		if err != nil {
+			logError("Failed to list yield baselines", "error", err)
+			http.Error(w, "failed to list yield baselines", http.StatusInternalServerError)
+			return
+		}
+		if baselines == nil {
+			baselines = []storage.YieldBaseline{}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"baselines": baselines,
+		})
+	case http.MethodPut:
+		if !authorizeOrReject(w, r, authz.ActionSettingsServerWrite, authz.ResourceRef{}) {
+			return
+		}
+		var req struct {
+			Baselines []storage.YieldBaseline `json:"baselines"`
+		}
+		if err := decodeJSONBody(r, &req); err != nil {
+			http.Error(w, "invalid JSON", http.StatusBadRequest)
+			return
+		}
+		for i := range req.Baselines {
+			req.Baselines[i].ID = 0
+			req.Baselines[i].Normalize()
+			if err := req.Baselines[i].Validate(); err != nil {
+				http.Error(w, fmt.Sprintf("baseline %d: %v", i+1, err), http.StatusBadRequest)
+				return
+			}
+		}
+		if req.Baselines == nil {
+			req.Baselines = []storage.YieldBaseline{}
+		}
+		if err := serverStore.ReplaceYieldBaselines(ctx, req.Baselines); err != nil {
+			logError("Failed to save yield baselines", "error", err)
+			http.Error(w, "failed to save yield baselines", http.StatusInternalServerError)
+			return
+		}
+
+		actorType, actorID, actorName, actorTenant := auditActorFromPrincipal(r)
+		logInfo("Yield baselines updated", "baselines", len(req.Baselines), "updated_by", actorName)
+		logAuditEntry(ctx, &storage.AuditEntry{
+			ActorType:  actorType,
+			ActorID:    actorID,
+			ActorName:  actorName,
+			TenantID:   actorTenant,
+			Action:     "settings.yield_baselines.update",
+			TargetType: "yield_baselines",
+			Details:    fmt.Sprintf("Replaced cartridge baseline library (%d entries)", len(req.Baselines)),
+			IPAddress:  extractClientIP(r),
+			UserAgent:  r.Header.Get("User-Agent"),
+		})
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success":   true,
+			"baselines": req.Baselines,
+		})
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}